@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"github.com/goatkit/goatflow/internal/backup"
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/version"
+)
+
+func backupUsage() {
+	fmt.Println("Usage: gk backup <command> [options]")
+	fmt.Println()
+	fmt.Println("Commands:")
+	fmt.Println("  create [--dir D] [--incremental | --base A]")
+	fmt.Println("                 Back up the database, sysconfig, attachments and plugins")
+	fmt.Println("  restore [--yes] A")
+	fmt.Println("                 Verify archive A and restore it over the current installation")
+	fmt.Println("  verify A       Check the checksums of archive A and the archives it needs")
+	fmt.Println("  list [--dir D] List the archives in a directory")
+	fmt.Println("  prune --keep-days N [--dir D]")
+	fmt.Println("                 Remove archives older than N days that no kept archive needs")
+	fmt.Println()
+	fmt.Println("--incremental bases the backup on the newest archive in the directory; only")
+	fmt.Println("changed tables and files are stored. The directory defaults to BACKUP_DIR.")
+	fmt.Println("Attachments are read from ARTICLE_STORAGE_FS_PATH and plugins from PLUGIN_DIR")
+	fmt.Println("(or CONFIG_DIR/plugins). Stop GoatFlow before restoring; the database must be")
+	fmt.Println("migrated to the schema version of the backup.")
+}
+
+func backupCommand(args []string) {
+	if len(args) == 0 {
+		backupUsage()
+		os.Exit(1)
+	}
+	cmd, args := args[0], args[1:]
+	fs := flag.NewFlagSet("gk backup "+cmd, flag.ExitOnError)
+	dir := fs.String("dir", os.Getenv("BACKUP_DIR"), "backup directory")
+
+	switch cmd {
+	case "create":
+		incremental := fs.Bool("incremental", false, "base the backup on the newest archive")
+		base := fs.String("base", "", "archive to base an incremental backup on")
+		_ = fs.Parse(args)
+		backupCreate(*dir, *incremental, *base)
+	case "restore":
+		yes := fs.Bool("yes", false, "restore without asking")
+		_ = fs.Parse(args)
+		if fs.NArg() != 1 {
+			fmt.Println("Usage: gk backup restore [--yes] <archive>")
+			os.Exit(1)
+		}
+		backupRestore(fs.Arg(0), *yes)
+	case "verify":
+		_ = fs.Parse(args)
+		if fs.NArg() != 1 {
+			fmt.Println("Usage: gk backup verify <archive>")
+			os.Exit(1)
+		}
+		m, err := backup.Verify(fs.Arg(0))
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("✅ %s: %d entries verified\n", filepath.Base(fs.Arg(0)), len(m.Entries))
+	case "list":
+		_ = fs.Parse(args)
+		backupList(backupDir(*dir))
+	case "prune":
+		keepDays := fs.Int("keep-days", 0, "days to keep archives")
+		_ = fs.Parse(args)
+		if *keepDays < 1 {
+			fmt.Println("Usage: gk backup prune --keep-days N [--dir D]")
+			os.Exit(1)
+		}
+		removed, err := backup.Prune(backupDir(*dir), time.Now().AddDate(0, 0, -*keepDays))
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		for _, name := range removed {
+			fmt.Printf("Removed %s\n", name)
+		}
+		fmt.Printf("✅ Removed %d archive(s)\n", len(removed))
+	case "help", "-h", "--help":
+		backupUsage()
+	default:
+		fmt.Printf("Unknown backup command: %s\n", cmd)
+		backupUsage()
+		os.Exit(1)
+	}
+}
+
+// backupDir exits when no backup directory is given.
+func backupDir(dir string) string {
+	if dir == "" {
+		fmt.Println("Error: no backup directory, pass --dir or set BACKUP_DIR")
+		os.Exit(1)
+	}
+	return dir
+}
+
+func backupCreate(dir string, incremental bool, base string) {
+	if base != "" {
+		if dir == "" {
+			dir = filepath.Dir(base)
+		}
+	} else {
+		dir = backupDir(dir)
+	}
+	if incremental && base == "" {
+		latest, err := backup.Latest(dir, "")
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		if latest == nil {
+			fmt.Println("No archive to base an incremental backup on, creating a full backup")
+		} else {
+			base = latest.Path
+		}
+	}
+
+	db, err := database.GetDB()
+	if err != nil {
+		fmt.Printf("Error connecting to database: %v\n", err)
+		os.Exit(1)
+	}
+	path, m, err := backup.Create(context.Background(), db, backup.Options{
+		Dir:        dir,
+		Driver:     database.GetDBDriver(),
+		Sources:    backup.SourcesFromEnv(),
+		Base:       base,
+		AppVersion: version.Version,
+	})
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	stored := 0
+	for _, e := range m.Entries {
+		if e.Archive == "" {
+			stored++
+		}
+	}
+	fmt.Printf("✅ Created %s backup %s (%d of %d entries stored, schema version %d)\n",
+		m.Kind, path, stored, len(m.Entries), m.SchemaVersion)
+}
+
+func backupRestore(path string, yes bool) {
+	m, err := backup.Verify(path)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("%s: %s backup of %s, schema version %d, taken %s\n", filepath.Base(path), m.Kind,
+		m.AppVersion, m.SchemaVersion, m.CreatedAt.Local().Format("2006-01-02 15:04:05"))
+	if !yes {
+		fmt.Println("Restoring replaces the database contents and overwrites the backed-up files.")
+		fmt.Println("Re-run with --yes to restore.")
+		os.Exit(1)
+	}
+
+	db, err := database.GetDB()
+	if err != nil {
+		fmt.Printf("Error connecting to database: %v\n", err)
+		os.Exit(1)
+	}
+	_, err = backup.Restore(context.Background(), db, path, backup.RestoreOptions{
+		Driver:  database.GetDBDriver(),
+		Sources: backup.SourcesFromEnv(),
+	})
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("✅ Restored %d entries from %s\n", len(m.Entries), filepath.Base(path))
+}
+
+func backupList(dir string) {
+	archives, err := backup.List(dir)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if len(archives) == 0 {
+		fmt.Printf("No archives in %s\n", dir)
+		return
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ARCHIVE\tKIND\tCREATED\tSCHEMA\tSIZE\tBASE")
+	for _, a := range archives {
+		baseName := a.Manifest.Base
+		if baseName == "" {
+			baseName = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%.1f MB\t%s\n", a.Name, a.Manifest.Kind,
+			a.Manifest.CreatedAt.Local().Format("2006-01-02 15:04:05"), a.Manifest.SchemaVersion,
+			float64(a.Size)/(1<<20), baseName)
+	}
+	_ = w.Flush()
+}
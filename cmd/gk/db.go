@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/goatkit/goatflow/internal/database"
+)
+
+func dbUsage() {
+	fmt.Println("Usage: gk db migrate [command]")
+	fmt.Println()
+	fmt.Println("Commands:")
+	fmt.Println("  up [--online]  Apply all pending migrations (default)")
+	fmt.Println("  down [N]       Revert the last N migrations (default 1)")
+	fmt.Println("  status         Show the applied and pending migrations")
+	fmt.Println("  force V        Set the version to V and clear the dirty flag")
+	fmt.Println("  repair         Accept the checksums of edited migrations")
+	fmt.Println()
+	fmt.Println("--online builds indexes and alters tables without blocking the running")
+	fmt.Println("application (CONCURRENTLY on PostgreSQL, ALGORITHM=INPLACE on MySQL) and")
+	fmt.Println("gives up on locks after --lock-timeout (default 5s), retrying --retries times.")
+	fmt.Println("--allow-offline lets MySQL changes that cannot run in place lock the table.")
+	fmt.Println()
+	fmt.Println("The database is configured by DB_DRIVER, DB_HOST, DB_PORT, DB_NAME, DB_USER")
+	fmt.Println("and DB_PASSWORD. MIGRATIONS_PATH reads migrations from a directory instead")
+	fmt.Println("of the ones built into gk.")
+}
+
+func dbCommand(args []string) {
+	if len(args) == 0 || args[0] != "migrate" {
+		dbUsage()
+		os.Exit(1)
+	}
+	args = args[1:]
+	cmd := "up"
+	if len(args) > 0 {
+		cmd, args = args[0], args[1:]
+	}
+
+	db, err := database.GetDB()
+	if err != nil {
+		fmt.Printf("Error connecting to database: %v\n", err)
+		os.Exit(1)
+	}
+	m, err := database.NewMigrator(db, database.GetDBDriver(), database.MigrationsFS())
+	if err != nil {
+		fmt.Printf("Error loading migrations: %v\n", err)
+		os.Exit(1)
+	}
+	ctx := context.Background()
+
+	switch cmd {
+	case "up":
+		opts, online := database.OnlineOptionsFromEnv()
+		fs := flag.NewFlagSet("gk db migrate up", flag.ExitOnError)
+		fs.BoolVar(&online, "online", online, "run expensive DDL online")
+		fs.DurationVar(&opts.LockTimeout, "lock-timeout", opts.LockTimeout, "how long a statement waits for a lock")
+		fs.IntVar(&opts.Retries, "retries", opts.Retries, "retries after a lock timeout")
+		fs.BoolVar(&opts.AllowOffline, "allow-offline", opts.AllowOffline, "run MySQL changes that cannot run in place offline")
+		_ = fs.Parse(args)
+		if online {
+			opts.Progress = func(p database.MigrationProgress) { fmt.Println(p) }
+			m.SetOnline(opts)
+		}
+		applied, err := m.Up(ctx)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			if applied > 0 {
+				fmt.Printf("%d migration(s) were applied before the error\n", applied)
+			}
+			os.Exit(1)
+		}
+		if applied == 0 {
+			fmt.Println("✅ Database schema is up to date")
+			return
+		}
+		fmt.Printf("✅ Applied %d migration(s)\n", applied)
+	case "down":
+		steps := 1
+		if len(args) > 0 {
+			if steps, err = strconv.Atoi(args[0]); err != nil || steps < 1 {
+				fmt.Printf("Invalid number of migrations: %s\n", args[0])
+				os.Exit(1)
+			}
+		}
+		reverted, err := m.Down(ctx, steps)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("✅ Reverted %d migration(s)\n", reverted)
+	case "status":
+		printMigrationStatus(ctx, m)
+	case "force":
+		if len(args) == 0 {
+			fmt.Println("Usage: gk db migrate force <version>")
+			os.Exit(1)
+		}
+		version, err := strconv.ParseUint(args[0], 10, 64)
+		if err != nil {
+			fmt.Printf("Invalid version: %s\n", args[0])
+			os.Exit(1)
+		}
+		if err := m.Force(ctx, uint(version)); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("✅ Database version set to %d\n", version)
+	case "repair":
+		repaired, err := m.Repair(ctx)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("✅ Updated %d checksum(s)\n", repaired)
+	case "help", "-h", "--help":
+		dbUsage()
+	default:
+		fmt.Printf("Unknown migrate command: %s\n", cmd)
+		dbUsage()
+		os.Exit(1)
+	}
+}
+
+func printMigrationStatus(ctx context.Context, m *database.Migrator) {
+	status, err := m.Status(ctx)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tSTATE\tAPPLIED AT")
+	for _, mig := range status.Migrations {
+		state := "pending"
+		switch {
+		case mig.Dirty:
+			state = "dirty"
+		case mig.Modified:
+			state = "modified"
+		case mig.Applied:
+			state = "applied"
+		}
+		appliedAt := "-"
+		if mig.AppliedAt != nil {
+			appliedAt = mig.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+		fmt.Fprintf(w, "%06d\t%s\t%s\t%s\n", mig.Version, mig.Name, state, appliedAt)
+	}
+	_ = w.Flush()
+
+	fmt.Println()
+	fmt.Printf("Version %d of %d, %d pending", status.Version, status.Latest, status.Pending)
+	if status.Modified > 0 {
+		fmt.Printf(", %d modified since applied (see `gk db migrate repair`)", status.Modified)
+	}
+	if status.Dirty {
+		fmt.Print(", DIRTY (fix the schema, then `gk db migrate force <version>`)")
+	}
+	fmt.Println()
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/helpdeskimport"
+	"github.com/goatkit/goatflow/internal/otrsimport"
+	"github.com/goatkit/goatflow/internal/ticketnumber"
+)
+
+func importUsage() {
+	fmt.Println("Usage: gk import otrs --source-driver mysql|postgres --source-dsn DSN [options]")
+	fmt.Println("       gk import zendesk --subdomain NAME --email EMAIL --token TOKEN [options]")
+	fmt.Println("       gk import freshdesk --domain NAME --api-key KEY [options]")
+	fmt.Println()
+	fmt.Println("Copies queues, users, groups, roles, tickets, articles, attachments and dynamic")
+	fmt.Println("fields from an OTRS 6+ or Znuny database into GoatFlow.")
+	fmt.Println()
+	fmt.Println("Options:")
+	fmt.Println("  --name N         Name of the source system in the ID map (default otrs)")
+	fmt.Println("  --dry-run        Report what would be imported without writing")
+	fmt.Println("  --batch-size N   Rows per transaction (default 500)")
+	fmt.Println("  --rescan         Go over all rows again instead of resuming")
+	fmt.Println("  --only E1,E2     Import only these entities")
+	fmt.Println("  --report FILE    Also write the report as JSON")
+	fmt.Println()
+	fmt.Println("Entities: " + strings.Join(otrsimport.EntityNames(), ", "))
+	fmt.Println()
+	fmt.Println("Re-running continues after the last imported rows and updates the rows that")
+	fmt.Println("changed in the source since, so an import can be repeated until cut-over.")
+	fmt.Println()
+	fmt.Println("zendesk and freshdesk pull organizations, users, tickets and comments with")
+	fmt.Println("their attachments through the REST API. The token and API key can also be")
+	fmt.Println("set in ZENDESK_API_TOKEN and FRESHDESK_API_KEY.")
+	fmt.Println()
+	fmt.Println("Options:")
+	fmt.Println("  --since TIME            Import tickets updated after TIME (RFC 3339); defaults")
+	fmt.Println("                          to the start of the last completed run")
+	fmt.Println("  --full                  Import all tickets")
+	fmt.Println("  --default-queue Q       Queue for tickets whose group has no queue (default Raw)")
+	fmt.Println("  --max-attachment-mb N   Skip larger attachments (default 20)")
+	fmt.Println()
+	fmt.Println("Progress is shown under Admin > Helpdesk Imports.")
+}
+
+func importCommand(args []string) {
+	if len(args) > 0 && (args[0] == "zendesk" || args[0] == "freshdesk") {
+		helpdeskImportCommand(args[0], args[1:])
+		return
+	}
+	if len(args) == 0 || args[0] != "otrs" {
+		importUsage()
+		os.Exit(1)
+	}
+	fs := flag.NewFlagSet("gk import otrs", flag.ExitOnError)
+	fs.Usage = importUsage
+	sourceDriver := fs.String("source-driver", "mysql", "source database driver")
+	sourceDSN := fs.String("source-dsn", "", "source database DSN")
+	name := fs.String("name", "otrs", "name of the source system")
+	dryRun := fs.Bool("dry-run", false, "report without writing")
+	batchSize := fs.Int("batch-size", otrsimport.DefaultBatchSize, "rows per transaction")
+	rescan := fs.Bool("rescan", false, "go over all rows again")
+	only := fs.String("only", "", "comma separated entities")
+	reportFile := fs.String("report", "", "write the report as JSON to this file")
+	_ = fs.Parse(args[1:])
+	if *sourceDSN == "" {
+		fmt.Println("Error: --source-dsn is required")
+		os.Exit(1)
+	}
+
+	src, err := openSource(*sourceDriver, *sourceDSN)
+	if err != nil {
+		fmt.Printf("Error connecting to source database: %v\n", err)
+		os.Exit(1)
+	}
+	defer src.Close()
+	db, err := database.GetDB()
+	if err != nil {
+		fmt.Printf("Error connecting to database: %v\n", err)
+		os.Exit(1)
+	}
+
+	opts := otrsimport.Options{
+		Source:       *name,
+		SourceDriver: *sourceDriver,
+		TargetDriver: database.GetDBDriver(),
+		BatchSize:    *batchSize,
+		DryRun:       *dryRun,
+		Rescan:       *rescan,
+		Progress: func(r otrsimport.EntityReport) {
+			fmt.Printf("\r%-22s %d scanned", r.Entity, r.Scanned)
+		},
+	}
+	if *only != "" {
+		opts.Entities = strings.Split(*only, ",")
+	}
+	report, runErr := otrsimport.New(src, db, opts).Run(context.Background())
+	fmt.Print("\r\033[K")
+	if report != nil {
+		_ = report.WriteText(os.Stdout)
+		if *reportFile != "" {
+			data, _ := json.MarshalIndent(report, "", "  ")
+			if err := os.WriteFile(*reportFile, data, 0o644); err != nil {
+				fmt.Printf("Error writing report: %v\n", err)
+			}
+		}
+	}
+	if runErr != nil {
+		fmt.Printf("Error: %v\n", runErr)
+		os.Exit(1)
+	}
+	if *dryRun {
+		fmt.Println("✅ Dry run finished, nothing was written")
+		return
+	}
+	fmt.Println("✅ Import finished")
+}
+
+// openSource connects to the OTRS database. MySQL dates are parsed so that
+// they copy into any GoatFlow database.
+func openSource(driver, dsn string) (*sql.DB, error) {
+	if driver == "mysql" || driver == "mariadb" {
+		cfg, err := mysql.ParseDSN(dsn)
+		if err != nil {
+			return nil, err
+		}
+		cfg.ParseTime = true
+		driver, dsn = "mysql", cfg.FormatDSN()
+	}
+	db, err := sql.Open(driver, dsn)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+func helpdeskImportCommand(kind string, args []string) {
+	fs := flag.NewFlagSet("gk import "+kind, flag.ExitOnError)
+	fs.Usage = importUsage
+	subdomain := fs.String("subdomain", "", "Zendesk subdomain")
+	email := fs.String("email", "", "Zendesk agent email the token belongs to")
+	token := fs.String("token", os.Getenv("ZENDESK_API_TOKEN"), "Zendesk API token")
+	domain := fs.String("domain", "", "Freshdesk domain")
+	apiKey := fs.String("api-key", os.Getenv("FRESHDESK_API_KEY"), "Freshdesk API key")
+	sinceFlag := fs.String("since", "", "import tickets updated after this time")
+	full := fs.Bool("full", false, "import all tickets")
+	defaultQueue := fs.String("default-queue", helpdeskimport.DefaultQueue, "queue for unmatched groups")
+	maxMB := fs.Int64("max-attachment-mb", helpdeskimport.DefaultMaxAttachmentSize>>20, "attachment size limit in MB")
+	_ = fs.Parse(args)
+
+	var src helpdeskimport.Source
+	switch kind {
+	case "zendesk":
+		if *subdomain == "" || *email == "" || *token == "" {
+			fmt.Println("Error: --subdomain, --email and --token are required")
+			os.Exit(1)
+		}
+		src = helpdeskimport.NewZendesk(*subdomain, *email, *token)
+	default:
+		if *domain == "" || *apiKey == "" {
+			fmt.Println("Error: --domain and --api-key are required")
+			os.Exit(1)
+		}
+		src = helpdeskimport.NewFreshdesk(*domain, *apiKey)
+	}
+
+	db, err := database.GetDB()
+	if err != nil {
+		fmt.Printf("Error connecting to database: %v\n", err)
+		os.Exit(1)
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	var since time.Time
+	switch {
+	case *sinceFlag != "":
+		if since, err = time.Parse(time.RFC3339, *sinceFlag); err != nil {
+			fmt.Printf("Error: invalid --since: %v\n", err)
+			os.Exit(1)
+		}
+	case !*full:
+		last, err := helpdeskimport.LastCompleted(ctx, db, src.Name(), src.Account())
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		if last != nil {
+			since = last.StartedAt
+			fmt.Printf("Continuing from %s\n", since.Format(time.RFC3339))
+		}
+	}
+
+	configDir := os.Getenv("CONFIG_DIR")
+	if configDir == "" {
+		configDir = "/app/config"
+	}
+	numbers := ticketnumber.SetupFromConfig(configDir)
+	store := ticketnumber.NewDBStore(db, numbers.SystemID)
+
+	opts := helpdeskimport.Options{
+		Since:             since,
+		DefaultQueue:      *defaultQueue,
+		MaxAttachmentSize: *maxMB << 20,
+		NextTicketNumber: func(ctx context.Context) (string, error) {
+			return numbers.Generator.Next(ctx, store)
+		},
+		Progress: func(p helpdeskimport.Progress) {
+			fmt.Printf("\r%-14s %d organizations, %d users, %d tickets, %d articles",
+				p.Phase, p.Organizations, p.Users, p.Tickets, p.Articles)
+		},
+	}
+	p, runErr := helpdeskimport.New(db, src, opts).Run(ctx)
+	fmt.Print("\r\033[K")
+	fmt.Printf("Organizations: %d\nUsers:         %d\nTickets:       %d\nArticles:      %d\nAttachments:   %d\nSkipped:       %d\n",
+		p.Organizations, p.Users, p.Tickets, p.Articles, p.Attachments, p.Skipped)
+	for _, issue := range p.Issues {
+		fmt.Printf("  - %s\n", issue)
+	}
+	if runErr != nil {
+		fmt.Printf("Error: %v\n", runErr)
+		os.Exit(1)
+	}
+	fmt.Println("✅ Import finished")
+}
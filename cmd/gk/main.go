@@ -23,16 +23,36 @@ func main() {
 	case "plugin":
 		if len(os.Args) < 3 {
 			fmt.Println("Usage: gk plugin <command>")
-			fmt.Println("Commands: init")
+			fmt.Println("Commands: init, sign, verify, test")
 			os.Exit(1)
 		}
 		switch os.Args[2] {
 		case "init":
 			pluginInit()
+		case "sign":
+			pluginSignCommand(os.Args[3:])
+		case "verify":
+			pluginVerifyCommand(os.Args[3:])
+		case "test":
+			pluginTestCommand(os.Args[3:])
 		default:
 			fmt.Printf("Unknown plugin command: %s\n", os.Args[2])
 			os.Exit(1)
 		}
+	case "keygen":
+		keygenCommand(os.Args[2:])
+	case "db":
+		dbCommand(os.Args[2:])
+	case "secrets":
+		secretsCommand(os.Args[2:])
+	case "backup":
+		backupCommand(os.Args[2:])
+	case "import":
+		importCommand(os.Args[2:])
+	case "user":
+		userCommand(os.Args[2:])
+	case "token":
+		tokenCommand(os.Args[2:])
 	case "help", "-h", "--help":
 		printUsage()
 	case "version", "-v", "--version":
@@ -45,12 +65,22 @@ func main() {
 }
 
 func printUsage() {
-	fmt.Println("GoatKit CLI - Plugin Development Tool")
+	fmt.Println("GoatKit CLI - Plugin Development and Admin Tool")
 	fmt.Println()
 	fmt.Println("Usage: gk <command> [arguments]")
 	fmt.Println()
 	fmt.Println("Commands:")
 	fmt.Println("  plugin init    Create a new plugin from template")
+	fmt.Println("  plugin sign    Write the detached signature of a plugin artifact")
+	fmt.Println("  plugin verify  Check a plugin artifact's signature against trusted keys")
+	fmt.Println("  plugin test    Run a built plugin's handlers against a mock host API")
+	fmt.Println("  keygen         Generate an ed25519 key pair for signing plugins")
+	fmt.Println("  db migrate     Apply, revert or list schema migrations")
+	fmt.Println("  secrets        Generate master keys and re-encrypt stored credentials")
+	fmt.Println("  backup         Create, verify and restore backup archives")
+	fmt.Println("  import         Import OTRS/Znuny databases and Zendesk/Freshdesk accounts")
+	fmt.Println("  user           Create agents, set passwords, lock, unlock and add to groups")
+	fmt.Println("  token          Create, list and revoke API tokens for automation")
 	fmt.Println("  help           Show this help message")
 	fmt.Println("  version        Show version information")
 }
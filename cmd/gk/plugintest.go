@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/goatkit/goatflow/internal/plugin"
+	grpcplugin "github.com/goatkit/goatflow/internal/plugin/grpc"
+	"github.com/goatkit/goatflow/internal/plugin/plugintest"
+	"github.com/goatkit/goatflow/internal/plugin/sidecar"
+	"github.com/goatkit/goatflow/internal/plugin/wasm"
+)
+
+func pluginTestUsage() {
+	fmt.Println("Usage: gk plugin test DIR [--testdata DIR] [--run REGEXP] [-v]")
+	fmt.Println()
+	fmt.Println("Load the plugin in DIR (the plugin.yaml of a sidecar plugin, NAME.wasm, or the")
+	fmt.Println("NAME or plugin executable of a gRPC plugin) against a mock host API and run")
+	fmt.Println("the cases in DIR/testdata.")
+	fmt.Println("DIR may also be the artifact itself.")
+	fmt.Println()
+	fmt.Println("testdata/host.json scripts the host: canned db_query rows, http_request")
+	fmt.Println("responses, config values, translations and feature flags. Every other .json")
+	fmt.Println("file holds a case (or an array of cases):")
+	fmt.Println()
+	fmt.Println(`  {"handler": "hello", "args": {"name": "Ann"},`)
+	fmt.Println(`   "expect": {"message": "Hello, Ann!"},`)
+	fmt.Println(`   "expect_calls": [{"fn": "db_query"}]}`)
+	fmt.Println()
+	fmt.Println("Options:")
+	fmt.Println("  --testdata DIR   Folder of cases (default DIR/testdata)")
+	fmt.Println("  --run REGEXP     Only run cases whose name matches")
+	fmt.Println("  -v               Print each case's result and host calls")
+}
+
+func pluginTestCommand(args []string) {
+	fs := flag.NewFlagSet("gk plugin test", flag.ExitOnError)
+	fs.Usage = pluginTestUsage
+	testdata := fs.String("testdata", "", "folder of cases")
+	run := fs.String("run", "", "only run cases whose name matches")
+	verbose := fs.Bool("v", false, "print results and host calls")
+	dir, rest := splitArtifact(args)
+	_ = fs.Parse(rest)
+	if dir == "" && fs.NArg() > 0 {
+		dir = fs.Arg(0)
+	}
+	if dir == "" {
+		pluginTestUsage()
+		os.Exit(1)
+	}
+
+	artifact, kind, err := findPluginArtifact(dir)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if *testdata == "" {
+		*testdata = filepath.Join(filepath.Dir(artifact), "testdata")
+	}
+	suite, err := plugintest.LoadSuite(*testdata)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if *run != "" {
+		re, err := regexp.Compile(*run)
+		if err != nil {
+			fmt.Printf("Error: --run: %v\n", err)
+			os.Exit(1)
+		}
+		var cases []plugintest.Case
+		for _, c := range suite.Cases {
+			if re.MatchString(c.Name) {
+				cases = append(cases, c)
+			}
+		}
+		suite.Cases = cases
+	}
+
+	ctx := context.Background()
+	host := plugintest.NewMockHost(suite.Host)
+	var p plugin.Plugin
+	switch kind {
+	case "wasm":
+		p, err = wasm.LoadFromFile(ctx, artifact, wasm.WithCallTimeout(30*time.Second))
+	case sidecar.RuntimeExec:
+		p, err = sidecar.Load(ctx, artifact)
+	default:
+		p, err = grpcplugin.LoadGRPCPlugin(artifact, host)
+	}
+	if err != nil {
+		fmt.Printf("Error loading %s: %v\n", artifact, err)
+		os.Exit(1)
+	}
+	if err := p.Init(ctx, host); err != nil {
+		_ = p.Shutdown(ctx)
+		fmt.Printf("Error initialising plugin: %v\n", err)
+		os.Exit(1)
+	}
+
+	reg := p.GKRegister()
+	fmt.Printf("=== %s %s (%s, %s)\n", reg.Name, reg.Version, kind, artifact)
+	declared := map[string]bool{}
+	for _, h := range plugintest.DeclaredHandlers(reg) {
+		declared[h] = true
+	}
+	failed := 0
+	for _, r := range suite.Run(ctx, p, host) {
+		note := ""
+		if !declared[r.Case.Handler] {
+			note = fmt.Sprintf(" (handler %q is not declared in the registration)", r.Case.Handler)
+		}
+		if r.Passed() {
+			fmt.Printf("PASS  %s (%s)%s\n", r.Case.Name, r.Duration.Round(time.Millisecond), note)
+		} else {
+			failed++
+			fmt.Printf("FAIL  %s (%s)%s\n", r.Case.Name, r.Duration.Round(time.Millisecond), note)
+			fmt.Printf("      %v\n", r.Err)
+		}
+		if *verbose || !r.Passed() {
+			if len(r.Output) > 0 {
+				fmt.Printf("      result: %s\n", r.Output)
+			}
+			for _, call := range r.Calls {
+				data, _ := json.Marshal(call)
+				fmt.Printf("      host: %s\n", data)
+			}
+		}
+	}
+
+	fmt.Println()
+	fmt.Printf("%d passed, %d failed\n", len(suite.Cases)-failed, failed)
+	if untested := suite.Untested(reg); len(untested) > 0 {
+		fmt.Printf("Handlers without cases: %s\n", strings.Join(untested, ", "))
+	}
+	_ = p.Shutdown(ctx)
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// findPluginArtifact locates the built plugin in a plugin directory as
+// `gk plugin init` lays it out, or accepts the artifact path itself.
+func findPluginArtifact(path string) (string, string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", "", err
+	}
+	if !info.IsDir() {
+		if filepath.Base(path) == sidecar.ManifestFile {
+			return path, sidecar.RuntimeExec, nil
+		}
+		if strings.HasSuffix(path, ".wasm") {
+			return path, "wasm", nil
+		}
+		return path, "grpc", nil
+	}
+	if p := filepath.Join(path, sidecar.ManifestFile); isFile(p) {
+		return p, sidecar.RuntimeExec, nil
+	}
+	name := filepath.Base(filepath.Clean(path))
+	if p := filepath.Join(path, name+".wasm"); isFile(p) {
+		return p, "wasm", nil
+	}
+	if matches, _ := filepath.Glob(filepath.Join(path, "*.wasm")); len(matches) == 1 {
+		return matches[0], "wasm", nil
+	}
+	for _, exe := range []string{name, "plugin"} {
+		p := filepath.Join(path, exe)
+		if info, err := os.Stat(p); err == nil && !info.IsDir() && info.Mode()&0o111 != 0 {
+			return p, "grpc", nil
+		}
+	}
+	return "", "", fmt.Errorf("no built plugin in %s (run its build.sh first)", path)
+}
+
+func isFile(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
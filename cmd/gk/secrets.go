@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/repository"
+	"github.com/goatkit/goatflow/internal/secrets"
+)
+
+func secretsUsage() {
+	fmt.Println("Usage: gk secrets <command>")
+	fmt.Println()
+	fmt.Println("Commands:")
+	fmt.Println("  keygen         Print a new random master key")
+	fmt.Println("  rotate         Re-encrypt stored credentials under the current master key")
+	fmt.Println()
+	fmt.Println("The master key is read from SECRETS_MASTER_KEY (or SECRETS_MASTER_KEY_FILE).")
+	fmt.Println("To rotate, set the new key there and the old one in SECRETS_MASTER_KEY_PREVIOUS,")
+	fmt.Println("run `gk secrets rotate`, then remove SECRETS_MASTER_KEY_PREVIOUS. Credentials")
+	fmt.Println("still stored in plaintext are encrypted by the same command.")
+}
+
+func secretsCommand(args []string) {
+	if len(args) == 0 {
+		secretsUsage()
+		os.Exit(1)
+	}
+	switch args[0] {
+	case "keygen":
+		key := make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Println(hex.EncodeToString(key))
+	case "rotate":
+		keys, err := secrets.Default()
+		if err != nil {
+			fmt.Printf("Error loading master keys: %v\n", err)
+			os.Exit(1)
+		}
+		if keys == nil {
+			fmt.Println("Error: SECRETS_MASTER_KEY is not set")
+			os.Exit(1)
+		}
+		db, err := database.GetDB()
+		if err != nil {
+			fmt.Printf("Error connecting to database: %v\n", err)
+			os.Exit(1)
+		}
+		changed, err := repository.NewWebserviceRepository(db).RotateSecrets(context.Background())
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			if changed > 0 {
+				fmt.Printf("%d webservice config(s) were re-encrypted before the error\n", changed)
+			}
+			os.Exit(1)
+		}
+		fmt.Printf("✅ Re-encrypted %d webservice config(s) under key %s\n", changed, keys.KeyID())
+	case "help", "-h", "--help":
+		secretsUsage()
+	default:
+		fmt.Printf("Unknown secrets command: %s\n", args[0])
+		secretsUsage()
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/service"
+)
+
+func tokenUsage() {
+	fmt.Println("Usage: gk token <command> [options]")
+	fmt.Println()
+	fmt.Println("Commands:")
+	fmt.Println("  create --user LOGIN [options]   Create an API token and print it once")
+	fmt.Println("  list [--user LOGIN] [--all]     List tokens; --all includes revoked ones")
+	fmt.Println("  revoke ID                       Revoke a token")
+	fmt.Println()
+	fmt.Println("Options of create:")
+	fmt.Println("  --scopes S1,S2    Scopes such as tickets:read,tickets:write (default: all")
+	fmt.Println("                    permissions of the user)")
+	fmt.Println("  --expires D       Lifetime such as 30d, 6m, 1y or never (default 90d)")
+	fmt.Println("  --name N          Name shown in the token list (default gk)")
+	fmt.Println("  --allowed-ips L   Comma separated addresses or CIDR ranges the token may be used from")
+	fmt.Println("  --customer        The user is a customer user rather than an agent")
+	fmt.Println("  --quiet           Print only the token, for scripts")
+	fmt.Println()
+	fmt.Println("Only a hash of the token is stored. The database is configured by DB_DRIVER,")
+	fmt.Println("DB_HOST, DB_PORT, DB_NAME, DB_USER and DB_PASSWORD.")
+}
+
+func tokenCommand(args []string) {
+	if len(args) == 0 {
+		tokenUsage()
+		os.Exit(1)
+	}
+	cmd := args[0]
+	switch cmd {
+	case "create", "list", "revoke":
+	case "help", "-h", "--help":
+		tokenUsage()
+		return
+	default:
+		fmt.Printf("Unknown token command: %s\n", cmd)
+		tokenUsage()
+		os.Exit(1)
+	}
+
+	fs := flag.NewFlagSet("gk token "+cmd, flag.ExitOnError)
+	fs.Usage = tokenUsage
+	user := fs.String("user", "", "login of the token's owner")
+	customer := fs.Bool("customer", false, "the user is a customer user")
+	scopes := fs.String("scopes", "", "comma separated scopes")
+	expires := fs.String("expires", "90d", "lifetime")
+	name := fs.String("name", "gk", "token name")
+	allowedIPs := fs.String("allowed-ips", "", "comma separated addresses or CIDR ranges")
+	quiet := fs.Bool("quiet", false, "print only the token")
+	all := fs.Bool("all", false, "include revoked tokens")
+	_ = fs.Parse(args[1:])
+
+	userType := models.APITokenUserAgent
+	if *customer {
+		userType = models.APITokenUserCustomer
+	}
+
+	db, err := database.GetDB()
+	if err != nil {
+		fmt.Printf("Error connecting to database: %v\n", err)
+		os.Exit(1)
+	}
+	svc := service.NewAPITokenService(db)
+	ctx := context.Background()
+
+	switch cmd {
+	case "create":
+		if *user == "" {
+			fmt.Println("Error: --user is required")
+			os.Exit(1)
+		}
+		userID, err := svc.UserIDByLogin(ctx, *user, userType)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		resp, err := svc.GenerateToken(ctx, &models.APITokenCreateRequest{
+			Name:       *name,
+			Scopes:     splitList(*scopes),
+			ExpiresIn:  *expires,
+			AllowedIPs: splitList(*allowedIPs),
+		}, userID, userType, cliUserID)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		if *quiet {
+			fmt.Println(resp.Token)
+			return
+		}
+		fmt.Printf("✅ Created token %d (%s) for %s %s\n", resp.ID, resp.Name, userType, *user)
+		if resp.ExpiresAt != nil {
+			fmt.Printf("Expires: %s\n", *resp.ExpiresAt)
+		}
+		fmt.Println()
+		fmt.Println(resp.Token)
+		fmt.Println()
+		fmt.Println(resp.Warning)
+	case "list":
+		printTokens(ctx, svc, *user, userType, *all)
+	case "revoke":
+		if fs.NArg() == 0 {
+			fmt.Println("Usage: gk token revoke <id>")
+			os.Exit(1)
+		}
+		id, err := strconv.ParseInt(fs.Arg(0), 10, 64)
+		if err != nil {
+			fmt.Printf("Invalid token ID: %s\n", fs.Arg(0))
+			os.Exit(1)
+		}
+		if err := svc.RevokeTokenAdmin(ctx, id, cliUserID); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("✅ Revoked token %d\n", id)
+	}
+}
+
+func printTokens(ctx context.Context, svc *service.APITokenService, user string, userType models.APITokenUserType, includeRevoked bool) {
+	userID := 0
+	if user != "" {
+		var err error
+		if userID, err = svc.UserIDByLogin(ctx, user, userType); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	tokens, err := svc.ListAllTokens(ctx, includeRevoked)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tUSER\tNAME\tPREFIX\tSCOPES\tEXPIRES\tLAST USED\tSTATE")
+	for _, t := range tokens {
+		if userID != 0 && (t.UserID != userID || t.UserType != userType) {
+			continue
+		}
+		owner, err := svc.OwnerLogin(ctx, t)
+		if err != nil || owner == "" {
+			owner = fmt.Sprintf("#%d", t.UserID)
+		}
+		if t.UserType == models.APITokenUserCustomer {
+			owner += " (customer)"
+		}
+		scopes := "all"
+		if len(t.Scopes) > 0 {
+			scopes = strings.Join(t.Scopes, ",")
+		}
+		expires, lastUsed := "never", "-"
+		if t.ExpiresAt.Valid {
+			expires = t.ExpiresAt.Time.Format("2006-01-02")
+		}
+		if t.LastUsedAt.Valid {
+			lastUsed = t.LastUsedAt.Time.Format("2006-01-02 15:04")
+		}
+		state := "active"
+		switch {
+		case t.IsRevoked():
+			state = "revoked"
+		case t.IsExpired():
+			state = "expired"
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s%s\t%s\t%s\t%s\t%s\n", t.ID, owner, t.Name, models.TokenPrefix, t.Prefix, scopes, expires, lastUsed, state)
+	}
+	_ = w.Flush()
+}
+
+// splitList splits a comma separated flag value, dropping empty entries.
+func splitList(s string) []string {
+	var out []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
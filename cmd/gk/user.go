@@ -0,0 +1,301 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/service"
+)
+
+func userUsage() {
+	fmt.Println("Usage: gk user <command> LOGIN [options]")
+	fmt.Println()
+	fmt.Println("Commands:")
+	fmt.Println("  create LOGIN --first-name F --last-name L   Create a valid agent")
+	fmt.Println("  password LOGIN                              Set a new password")
+	fmt.Println("  lock LOGIN                                  Lock the agent out")
+	fmt.Println("  unlock LOGIN                                Make the agent valid again and reset failed logins")
+	fmt.Println("  add-to-group LOGIN GROUP [--permission rw]  Give the agent a permission in a group")
+	fmt.Println()
+	fmt.Println("Options:")
+	fmt.Println("  --title T          Title of a new agent")
+	fmt.Println("  --group G          Group to join with rw (repeatable)")
+	fmt.Println("  --admin            Join the admin group")
+	fmt.Println("  --password P       Password; without it one is generated and printed")
+	fmt.Println("  --password-stdin   Read the password from the first line of stdin")
+	fmt.Println("  --url URL          Use the REST API of this server instead of the database")
+	fmt.Println("  --token TOKEN      API token of an admin (GOATFLOW_TOKEN)")
+	fmt.Println()
+	fmt.Println("Passwords must meet the agent password policy. Without --url the database")
+	fmt.Println("is configured by DB_DRIVER, DB_HOST, DB_PORT, DB_NAME, DB_USER and DB_PASSWORD;")
+	fmt.Println("GOATFLOW_URL sets --url.")
+}
+
+// userAdmin is what the user commands need, from the database or the API.
+type userAdmin interface {
+	CreateAgent(ctx context.Context, a service.NewAgent) error
+	SetPassword(ctx context.Context, login, password string) error
+	Lock(ctx context.Context, login string) error
+	Unlock(ctx context.Context, login string) error
+	AddToGroup(ctx context.Context, login, group, permission string) error
+}
+
+// stringList is a repeatable string flag.
+type stringList []string
+
+func (l *stringList) String() string     { return strings.Join(*l, ",") }
+func (l *stringList) Set(v string) error { *l = append(*l, v); return nil }
+
+func userCommand(args []string) {
+	if len(args) < 2 || strings.HasPrefix(args[1], "-") {
+		userUsage()
+		os.Exit(1)
+	}
+	cmd, login := args[0], args[1]
+	switch cmd {
+	case "create", "password", "lock", "unlock", "add-to-group":
+	default:
+		fmt.Printf("Unknown user command: %s\n", cmd)
+		userUsage()
+		os.Exit(1)
+	}
+	fs := flag.NewFlagSet("gk user "+cmd, flag.ExitOnError)
+	fs.Usage = userUsage
+	firstName := fs.String("first-name", "", "first name of a new agent")
+	lastName := fs.String("last-name", "", "last name of a new agent")
+	title := fs.String("title", "", "title of a new agent")
+	var groups stringList
+	fs.Var(&groups, "group", "group to join with rw")
+	admin := fs.Bool("admin", false, "join the admin group")
+	password := fs.String("password", "", "password")
+	passwordStdin := fs.Bool("password-stdin", false, "read the password from stdin")
+	permission := fs.String("permission", "rw", "group permission")
+	apiURL := fs.String("url", os.Getenv("GOATFLOW_URL"), "server URL")
+	token := fs.String("token", os.Getenv("GOATFLOW_TOKEN"), "API token")
+
+	rest := args[2:]
+	var group string
+	if cmd == "add-to-group" {
+		if len(rest) == 0 || strings.HasPrefix(rest[0], "-") {
+			fmt.Println("Usage: gk user add-to-group LOGIN GROUP [--permission rw]")
+			os.Exit(1)
+		}
+		group, rest = rest[0], rest[1:]
+	}
+	_ = fs.Parse(rest)
+	if fs.NArg() > 0 {
+		fmt.Printf("Unexpected argument: %s\n", fs.Arg(0))
+		os.Exit(1)
+	}
+
+	generated := false
+	if cmd == "create" || cmd == "password" {
+		switch {
+		case *passwordStdin:
+			line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+			if err != nil && line == "" {
+				fmt.Printf("Error reading password: %v\n", err)
+				os.Exit(1)
+			}
+			*password = strings.TrimRight(line, "\r\n")
+		case *password == "":
+			*password, generated = generatePassword(), true
+		}
+	}
+
+	var ua userAdmin
+	if *apiURL != "" {
+		if *token == "" {
+			fmt.Println("Error: --token (or GOATFLOW_TOKEN) is required with --url")
+			os.Exit(1)
+		}
+		ua = &apiUserAdmin{baseURL: strings.TrimRight(*apiURL, "/"), token: *token, client: &http.Client{Timeout: 30 * time.Second}}
+	} else {
+		db, err := database.GetDB()
+		if err != nil {
+			fmt.Printf("Error connecting to database: %v\n", err)
+			os.Exit(1)
+		}
+		ua = &dbUserAdmin{svc: service.NewUserAdminService(db)}
+	}
+
+	ctx := context.Background()
+	var err error
+	switch cmd {
+	case "create":
+		if *admin {
+			groups = append(groups, "admin")
+		}
+		err = ua.CreateAgent(ctx, service.NewAgent{
+			Login: login, FirstName: *firstName, LastName: *lastName, Title: *title,
+			Password: *password, Groups: groups,
+		})
+		if err == nil {
+			fmt.Printf("✅ Created agent %s\n", login)
+		}
+	case "password":
+		if err = ua.SetPassword(ctx, login, *password); err == nil {
+			fmt.Printf("✅ Set the password of %s\n", login)
+		}
+	case "lock":
+		if err = ua.Lock(ctx, login); err == nil {
+			fmt.Printf("✅ Locked %s\n", login)
+		}
+	case "unlock":
+		if err = ua.Unlock(ctx, login); err == nil {
+			fmt.Printf("✅ Unlocked %s\n", login)
+		}
+	case "add-to-group":
+		if err = ua.AddToGroup(ctx, login, group, *permission); err == nil {
+			fmt.Printf("✅ Gave %s %s in %s\n", login, *permission, group)
+		}
+	}
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	if generated {
+		fmt.Printf("Password: %s\n", *password)
+	}
+}
+
+// generatePassword returns a random password of letters and digits with at
+// least two of each kind, which meets the common password policies.
+func generatePassword() string {
+	const (
+		lower  = "abcdefghijkmnopqrstuvwxyz"
+		upper  = "ABCDEFGHJKLMNPQRSTUVWXYZ"
+		digits = "23456789"
+	)
+	pick := func(set string) byte {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(set))))
+		if err != nil {
+			panic(err)
+		}
+		return set[n.Int64()]
+	}
+	for {
+		b := make([]byte, 20)
+		for i := range b {
+			b[i] = pick(lower + upper + digits)
+		}
+		pw := string(b)
+		if countAny(pw, lower) >= 2 && countAny(pw, upper) >= 2 && countAny(pw, digits) >= 2 {
+			return pw
+		}
+	}
+}
+
+func countAny(s, chars string) int {
+	n := 0
+	for _, r := range s {
+		if strings.ContainsRune(chars, r) {
+			n++
+		}
+	}
+	return n
+}
+
+// dbUserAdmin changes agents in the database as the admin user.
+type dbUserAdmin struct {
+	svc *service.UserAdminService
+}
+
+// cliUserID is the user changes made through the database are made by.
+const cliUserID = 1
+
+func (d *dbUserAdmin) CreateAgent(ctx context.Context, a service.NewAgent) error {
+	_, err := d.svc.CreateAgent(ctx, a, cliUserID)
+	return err
+}
+
+func (d *dbUserAdmin) SetPassword(ctx context.Context, login, password string) error {
+	return d.svc.SetPassword(ctx, login, password, cliUserID)
+}
+
+func (d *dbUserAdmin) Lock(ctx context.Context, login string) error {
+	return d.svc.Lock(ctx, login, cliUserID)
+}
+
+func (d *dbUserAdmin) Unlock(ctx context.Context, login string) error {
+	return d.svc.Unlock(ctx, login, cliUserID)
+}
+
+func (d *dbUserAdmin) AddToGroup(ctx context.Context, login, group, permission string) error {
+	return d.svc.AddToGroup(ctx, login, group, permission, cliUserID)
+}
+
+// apiUserAdmin changes agents through /api/v1/admin/agents.
+type apiUserAdmin struct {
+	baseURL string
+	token   string
+	client  *http.Client
+}
+
+func (a *apiUserAdmin) CreateAgent(ctx context.Context, agent service.NewAgent) error {
+	return a.do(ctx, http.MethodPost, "/api/v1/admin/agents", agent)
+}
+
+func (a *apiUserAdmin) SetPassword(ctx context.Context, login, password string) error {
+	return a.do(ctx, http.MethodPut, "/api/v1/admin/agents/"+url.PathEscape(login)+"/password",
+		map[string]string{"password": password})
+}
+
+func (a *apiUserAdmin) Lock(ctx context.Context, login string) error {
+	return a.do(ctx, http.MethodPut, "/api/v1/admin/agents/"+url.PathEscape(login)+"/lock", nil)
+}
+
+func (a *apiUserAdmin) Unlock(ctx context.Context, login string) error {
+	return a.do(ctx, http.MethodDelete, "/api/v1/admin/agents/"+url.PathEscape(login)+"/lock", nil)
+}
+
+func (a *apiUserAdmin) AddToGroup(ctx context.Context, login, group, permission string) error {
+	return a.do(ctx, http.MethodPut, "/api/v1/admin/agents/"+url.PathEscape(login)+"/groups/"+url.PathEscape(group),
+		map[string]string{"permission": permission})
+}
+
+func (a *apiUserAdmin) do(ctx context.Context, method, path string, body any) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, method, a.baseURL+path, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+a.token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 300 {
+		return nil
+	}
+	var result struct {
+		Error string `json:"error"`
+	}
+	if json.NewDecoder(resp.Body).Decode(&result) == nil && result.Error != "" {
+		return errors.New(result.Error)
+	}
+	return fmt.Errorf("%s %s: %s", method, path, resp.Status)
+}
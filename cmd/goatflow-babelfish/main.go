@@ -33,7 +33,7 @@ func main() {
 	var (
 		action  = flag.String("action", "coverage", "Action to perform: coverage, missing, validate, export, import")
 		lang    = flag.String("lang", "", "Language code (required for some actions)")
-		format  = flag.String("format", "text", "Output format: text, json, csv")
+		format  = flag.String("format", "text", "Output format: text, json, csv (po for export/import)")
 		file    = flag.String("file", "", "File path for import/export")
 		verbose = flag.Bool("v", false, "Verbose output")
 		quiet   = flag.Bool("q", false, "Quiet mode (no ASCII art)")
@@ -53,6 +53,7 @@ func main() {
 		fmt.Fprintf(os.Stderr, "  goatflow-babelfish -action=validate -lang=de           # Validate German translations\n")
 		fmt.Fprintf(os.Stderr, "  goatflow-babelfish -action=export -lang=en -file=en.csv -format=csv\n")
 		fmt.Fprintf(os.Stderr, "  goatflow-babelfish -action=import -lang=fr -file=fr.json\n")
+		fmt.Fprintf(os.Stderr, "  goatflow-babelfish -action=export -lang=de -file=de.po -format=po\n")
 		fmt.Fprintf(os.Stderr, "\nSupported languages: en, es, fr, de, pt, ja, zh, ar, ru, it, nl, tlh (Klingon!)\n")
 		fmt.Fprintf(os.Stderr, "\n✨ Remember: The answer is 42, but what's the question?\n")
 	}
@@ -368,8 +369,8 @@ func validateAll(i18n *i18n.I18n, verbose bool) {
 	}
 }
 
-func exportTranslations(i18n *i18n.I18n, lang string, filePath string, format string) {
-	translations := i18n.GetTranslations(lang)
+func exportTranslations(tr *i18n.I18n, lang string, filePath string, format string) {
+	translations := tr.GetTranslations(lang)
 	if translations == nil {
 		fmt.Fprintf(os.Stderr, "Language %s not found\n", lang)
 		os.Exit(1)
@@ -403,6 +404,13 @@ func exportTranslations(i18n *i18n.I18n, lang string, filePath string, format st
 			os.Exit(1)
 		}
 		fmt.Printf("Exported %s translations to %s (CSV format)\n", lang, filePath)
+	case "po":
+		source := i18n.FlattenTranslations(tr.GetTranslations("en"))
+		if err := i18n.WritePO(file, lang, i18n.FlattenTranslations(translations), source); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing PO: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Exported %s translations to %s (PO format)\n", lang, filePath)
 	default:
 		encoder := json.NewEncoder(file)
 		encoder.SetIndent("", "  ")
@@ -434,7 +442,7 @@ func exportToCSV(m map[string]interface{}, prefix string, w *csv.Writer) error {
 	return nil
 }
 
-func importTranslations(i18n *i18n.I18n, lang string, filePath string, format string) {
+func importTranslations(tr *i18n.I18n, lang string, filePath string, format string) {
 	file, err := os.Open(filePath) //nolint:gosec // G304 CLI tool
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error opening file: %v\n", err)
@@ -470,6 +478,18 @@ func importTranslations(i18n *i18n.I18n, lang string, filePath string, format st
 		saveTranslations(translations, outputPath)
 		fmt.Printf("Imported translations from %s to %s\n", filePath, outputPath)
 
+	case "po":
+		entries, err := i18n.ParsePO(file)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading PO: %v\n", err)
+			os.Exit(1)
+		}
+
+		// Save to file
+		outputPath := filepath.Join("internal", "i18n", "translations", lang+".json")
+		saveTranslations(i18n.UnflattenTranslations(entries), outputPath)
+		fmt.Printf("Imported translations from %s to %s\n", filePath, outputPath)
+
 	default:
 		var translations map[string]interface{}
 		decoder := json.NewDecoder(file)
@@ -25,7 +25,7 @@ func main() {
 		dbUser     = flag.String("db-user", getEnv("DB_USER", "goatflow"), "Database user")
 		dbPassword = flag.String("db-password", getEnv("DB_PASSWORD", ""), "Database password")
 
-		command = flag.String("command", "", "Command to execute: switch, status, verify")
+		command = flag.String("command", "", "Command to execute: switch, status, verify, decompress")
 		target  = flag.String("target", "", "Target backend: DB or FS")
 
 		fsPath = flag.String("fs-path", getEnv("ARTICLE_STORAGE_FS_PATH", "/opt/goatflow/var/article"), "Filesystem storage path")
@@ -44,11 +44,12 @@ func main() {
 
 	// Validate command
 	if *command == "" {
-		fmt.Println("Usage: goatflow-storage -command <switch|status|verify> [options]")
+		fmt.Println("Usage: goatflow-storage -command <switch|status|verify|decompress> [options]")
 		fmt.Println("\nCommands:")
 		fmt.Println("  switch  - Switch storage backend (requires -target)")
 		fmt.Println("  status  - Show current storage status")
 		fmt.Println("  verify  - Verify storage integrity")
+		fmt.Println("  decompress - Expand compressed article content (required before returning to OTRS)")
 		fmt.Println("\nOptions:")
 		flag.PrintDefaults()
 		os.Exit(1)
@@ -94,6 +95,9 @@ func main() {
 	case "verify":
 		err = verifyStorage(ctx, db, *fsPath, *verbose)
 
+	case "decompress":
+		err = decompressStorage(ctx, db, *batchSize, *dryRun)
+
 	default:
 		log.Fatalf("Unknown command: %s", *command)
 	}
@@ -496,6 +500,23 @@ func completeMigration(ctx context.Context, db *sql.DB, id, total, processed, fa
 		WHERE id = $1`, id, status, total, processed, failed)
 }
 
+// decompressStorage rewrites every compressed article body and attachment
+// back to its original content.
+func decompressStorage(ctx context.Context, db *sql.DB, batchSize int, dryRun bool) error {
+	r := storage.NewRecompressor(db, &storage.Compression{})
+	r.BatchSize = batchSize
+	r.DryRun = dryRun
+	r.Decompress = true
+
+	stats, err := r.Run(ctx)
+	fmt.Printf("Scanned: %d, decompressed: %d, failed: %d\n", stats.Scanned, stats.Changed, stats.Failed)
+	fmt.Printf("Size: %s -> %s\n", formatBytes(stats.BytesBefore), formatBytes(stats.BytesAfter))
+	if dryRun {
+		fmt.Println("Dry run - no changes were made")
+	}
+	return err
+}
+
 func formatBytes(bytes int64) string {
 	const unit = 1024
 	if bytes < unit {
@@ -9,6 +9,7 @@ import (
 
 	_ "github.com/go-sql-driver/mysql"
 	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
 	"github.com/spf13/cobra"
 	"golang.org/x/crypto/bcrypt"
 
@@ -244,9 +245,13 @@ func runResetUser(cmd *cobra.Command, args []string) error {
 			dbPort = "3306"
 		}
 	}
+	sqlite := dbDriver == "sqlite" || dbDriver == "sqlite3"
 	dbName := os.Getenv("DB_NAME")
 	if dbName == "" {
 		dbName = "goatflow"
+		if sqlite {
+			dbName = "goatflow.db"
+		}
 	}
 	dbUser := os.Getenv("DB_USER")
 	if dbUser == "" {
@@ -260,7 +265,7 @@ func runResetUser(cmd *cobra.Command, args []string) error {
 	if dbPassword == "" {
 		dbPassword = os.Getenv("PGPASSWORD")
 	}
-	if dbPassword == "" {
+	if dbPassword == "" && !sqlite {
 		return fmt.Errorf("database password environment variable is required (DB_PASSWORD or PGPASSWORD)")
 	}
 
@@ -279,6 +284,10 @@ func runResetUser(cmd *cobra.Command, args []string) error {
 		// Use MySQL DSN format
 		dsn = fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?parseTime=true&charset=utf8mb4,utf8&loc=Local",
 			dbUser, dbPassword, dbHost, dbPort, dbName)
+	case "sqlite", "sqlite3":
+		// DB_NAME is the path of the database file
+		driverName = "sqlite3"
+		dsn = "file:" + dbName + "?_busy_timeout=5000"
 	default:
 		// Fallback: try postgres style first
 		driverName = "postgres"
@@ -286,7 +295,11 @@ func runResetUser(cmd *cobra.Command, args []string) error {
 			dbHost, dbPort, dbUser, dbPassword, dbName)
 	}
 
-	fmt.Printf("🔗 Connecting to database (%s) %s@%s:%s/%s...\n", driverName, dbUser, dbHost, dbPort, dbName)
+	if sqlite {
+		fmt.Printf("🔗 Opening database (%s) %s...\n", driverName, dbName)
+	} else {
+		fmt.Printf("🔗 Connecting to database (%s) %s@%s:%s/%s...\n", driverName, dbUser, dbHost, dbPort, dbName)
+	}
 	db, err := sql.Open(driverName, dsn)
 	if err != nil {
 		return fmt.Errorf("failed to connect to database: %w", err)
@@ -310,8 +323,8 @@ func runResetUser(cmd *cobra.Command, args []string) error {
 		// Create minimal user row
 		fmt.Println("👤 Creating minimal user row...")
 		ins := database.ConvertPlaceholders(`
-            INSERT INTO users (login, pw, first_name, last_name, valid_id, create_by, change_by)
-            VALUES (?, '', '', '', 1, 1, 1)`)
+            INSERT INTO users (login, pw, first_name, last_name, valid_id, create_time, create_by, change_time, change_by)
+            VALUES (?, '', '', '', 1, NOW(), 1, NOW(), 1)`)
 		if _, ie := db.Exec(ins, usernameFlag); ie != nil {
 			// continue; update might still succeed if column set differs
 			fmt.Printf("⚠️  Warning: failed to insert minimal user row: %v\n", ie)
@@ -25,43 +25,59 @@ package main
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"flag"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/davidbyttow/govips/v2/vips"
 	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
 
+	"github.com/goatkit/goatflow/internal/antivirus"
 	"github.com/goatkit/goatflow/internal/api"
 
 	"github.com/goatkit/goatflow/internal/cache"
 	"github.com/goatkit/goatflow/internal/config"
+	"github.com/goatkit/goatflow/internal/coordination"
 	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/database/stmtcache"
 	"github.com/goatkit/goatflow/internal/email/inbound/connector"
 	"github.com/goatkit/goatflow/internal/email/inbound/filters"
 	"github.com/goatkit/goatflow/internal/email/inbound/postmaster"
+	"github.com/goatkit/goatflow/internal/events"
+	"github.com/goatkit/goatflow/internal/grpcapi"
+	"github.com/goatkit/goatflow/internal/jobqueue"
 	"github.com/goatkit/goatflow/internal/lookups"
 	"github.com/goatkit/goatflow/internal/middleware"
 	"github.com/goatkit/goatflow/internal/notifications"
 	"github.com/goatkit/goatflow/internal/plugin"
-	"github.com/goatkit/goatflow/internal/plugin/core"
-	"github.com/goatkit/goatflow/internal/plugin/example"
 	pluginloader "github.com/goatkit/goatflow/internal/plugin/loader"
+	"github.com/goatkit/goatflow/internal/plugin/wasm"
+	"github.com/goatkit/goatflow/internal/preview"
 	"github.com/goatkit/goatflow/internal/repository"
 	"github.com/goatkit/goatflow/internal/routing"
 	"github.com/goatkit/goatflow/internal/runner"
 	"github.com/goatkit/goatflow/internal/runner/tasks"
 	"github.com/goatkit/goatflow/internal/service"
+	"github.com/goatkit/goatflow/internal/services"
 	"github.com/goatkit/goatflow/internal/services/adapter"
 	"github.com/goatkit/goatflow/internal/services/k8s"
 	"github.com/goatkit/goatflow/internal/services/scheduler"
 	"github.com/goatkit/goatflow/internal/shared"
+	"github.com/goatkit/goatflow/internal/storage"
 	"github.com/goatkit/goatflow/internal/ticketnumber"
+	"github.com/goatkit/goatflow/internal/tracing"
+	"github.com/goatkit/goatflow/internal/version"
 	"github.com/goatkit/goatflow/internal/yamlmgmt"
 )
 
@@ -76,6 +92,36 @@ func main() {
 	var mode = flag.String("mode", "server", "Run mode: server (default) or runner")
 	flag.Parse()
 
+	// `goats serve` loads a config file, reloads it on SIGHUP and drains
+	// requests on shutdown
+	var serve serveOptions
+	serving := flag.Arg(0) == "serve"
+	if serving {
+		var err error
+		if serve, err = parseServeArgs(flag.Args()[1:], os.Stderr); err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return
+			}
+			fmt.Fprintf(os.Stderr, "serve: %v\n", err)
+			os.Exit(2)
+		}
+		if serve.configFile != "" {
+			dir := os.Getenv("CONFIG_DIR")
+			if dir == "" {
+				dir = "/app/config"
+			}
+			if err := config.LoadFile(dir, serve.configFile); err != nil {
+				fmt.Fprintf(os.Stderr, "serve: %v\n", err)
+				os.Exit(2)
+			}
+			if serve.check {
+				fmt.Printf("✅ %s is valid\n", serve.configFile)
+				return
+			}
+			log.Printf("✅ Config loaded from %s", serve.configFile)
+		}
+	}
+
 	// Initialize service registry early
 	log.Println("Initializing service registry...")
 	registry, err := adapter.InitializeServiceRegistry()
@@ -123,6 +169,19 @@ func main() {
 	if valkeyCache != nil {
 		api.SetValkeyCache(valkeyCache)
 	}
+	initPermissionCache(cfg)
+	initStatementCache(cfg)
+	tracer := initTracing(cfg)
+	defer shutdownTracing(tracer)
+
+	// Compression of article content stored in the database
+	if cfg != nil {
+		if compression, err := storage.CompressionFromConfig(cfg.Storage.Compression); err != nil {
+			log.Printf("Warning: article compression disabled: %v", err)
+		} else {
+			storage.SetCompression(compression)
+		}
+	}
 
 	// Get database connection
 	db, dbErr := database.GetDB()
@@ -134,22 +193,34 @@ func main() {
 	}
 
 	// Run database migrations automatically on startup
+	if cfg != nil {
+		database.SetMigrationsPath(cfg.Database.Migrations.Path)
+	}
 	if db != nil {
-		log.Println("Running database migrations...")
-		applied, err := database.RunMigrations(db)
-		if err != nil {
-			log.Printf("⚠️  Migration warning: %v", err)
-			// Don't fail startup - migrations use IF NOT EXISTS patterns
-		} else if applied > 0 {
-			log.Printf("✅ Applied %d migration(s)", applied)
+		if cfg != nil && !cfg.Database.Migrations.AutoMigrate {
+			log.Println("Automatic migrations disabled, run `gk db migrate` to apply pending migrations")
 		} else {
-			log.Println("✅ Database schema is up to date")
+			log.Println("Running database migrations...")
+			applied, err := database.RunMigrations(db)
+			if err != nil {
+				log.Printf("⚠️  Migration warning: %v", err)
+				// Don't fail startup - migrations use IF NOT EXISTS patterns
+			} else if applied > 0 {
+				log.Printf("✅ Applied %d migration(s)", applied)
+			} else {
+				log.Println("✅ Database schema is up to date")
+			}
 		}
 
 		// Initialize API token service (enables gf_* token authentication)
 		api.InitAPITokenService(db)
 	}
 
+	// Maintenance commands, e.g. `goats plugin doctor --fix`
+	if flag.NArg() > 0 && !serving {
+		os.Exit(runCommand(db, configDir, flag.Args()))
+	}
+
 	// Handle runner mode
 	if *mode == "runner" {
 		runRunner(db)
@@ -186,6 +257,30 @@ func main() {
 		log.Println("⚠️  Email provider not configured - notifications disabled")
 	}
 
+	// Attachment antivirus scanning
+	if cfg := config.Get(); cfg != nil {
+		scanner, err := antivirus.NewFromConfig(cfg.Storage.Attachments.Scan)
+		switch {
+		case err != nil:
+			log.Printf("⚠️  Antivirus scanning disabled: %v", err)
+		case scanner != nil:
+			antivirus.SetDefault(scanner)
+			log.Printf("🛡️  Attachment scanning enabled (%s at %s)", scanner.Name(), cfg.Storage.Attachments.Scan.Address)
+		}
+	}
+
+	// Attachment thumbnails and previews
+	if cfg := config.Get(); cfg != nil {
+		previews, err := preview.NewFromConfig(cfg.Storage)
+		switch {
+		case err != nil:
+			log.Printf("⚠️  Attachment previews disabled: %v", err)
+		case previews != nil:
+			preview.SetDefault(previews)
+			log.Println("🖼️  Attachment previews enabled")
+		}
+	}
+
 	// Ticket number generator wiring (prep refactor)
 	setup := ticketnumber.SetupFromConfig(configDir)
 	// Provide adapter to auth service (unchanged behavior)
@@ -210,20 +305,10 @@ func main() {
 			} else {
 				log.Printf("postmaster: database storage init failed: %v", err)
 			}
+		} else if svc, err := service.NewLocalStorageService(localStoragePath(config.Get(), configDir)); err == nil {
+			storageSvc = svc
 		} else {
-			storagePath := os.Getenv("STORAGE_PATH")
-			if storagePath == "" {
-				if cfg := config.Get(); cfg != nil && cfg.Storage.Local.Path != "" {
-					storagePath = cfg.Storage.Local.Path
-				} else {
-					storagePath = filepath.Join(configDir, "storage")
-				}
-			}
-			if svc, err := service.NewLocalStorageService(storagePath); err == nil {
-				storageSvc = svc
-			} else {
-				log.Printf("postmaster: local storage init failed: %v", err)
-			}
+			log.Printf("postmaster: local storage init failed: %v", err)
 		}
 		dispatchRulesPath := filepath.Join(configDir, "email_dispatch.yaml")
 		dispatchProvider, err := filters.NewFileDispatchRuleProvider(dispatchRulesPath)
@@ -250,12 +335,18 @@ func main() {
 			postmaster.WithTicketProcessorArticleStore(articleRepo),
 			postmaster.WithTicketProcessorMessageLookup(articleRepo),
 			postmaster.WithTicketProcessorDatabase(db),
+			postmaster.WithTicketProcessorScanner(service.NewDefaultAttachmentScanService(db)),
+			postmaster.WithTicketProcessorAutoResponder(service.NewAutoResponseService(db)),
+			postmaster.WithTicketProcessorVisibleText(repository.NewArticleVisibleTextRepository(db)),
 		)
 		var filterList []filters.Filter
 		// DBSourceFilter runs first to apply database-configured postmaster filters
 		// (equivalent to OTRS's PostMaster::PreFilterModule###000-MatchDBSource)
 		filterList = append(filterList, filters.NewDBSourceFilter(db, log.Default()))
+		emailLoops := service.NewEmailLoopService(db)
 		filterList = append(filterList,
+			filters.NewLoopProtectionFilter(emailLoops, log.Default()),
+			filters.NewBounceFilter(emailLoops, log.Default()),
 			filters.NewHeaderTokenFilter(log.Default()),
 			filters.NewSubjectTokenFilter(log.Default()),
 			filters.NewBodyTokenFilter(log.Default()),
@@ -281,6 +372,9 @@ func main() {
 
 	// Create router for YAML routes
 	r := gin.New()
+	if err := middleware.ConfigureClientIP(r); err != nil {
+		log.Printf("⚠️  Invalid trusted proxy configuration: %v", err)
+	}
 
 	customerOnly := strings.EqualFold(os.Getenv("CUSTOMER_FE_ONLY"), "true") || os.Getenv("CUSTOMER_FE_ONLY") == "1"
 	if customerOnly {
@@ -292,6 +386,11 @@ func main() {
 		})
 	}
 
+	// Tracing comes first so its span covers the rest of the chain
+	r.Use(middleware.Tracing())
+	// Opt-in usage telemetry, counted per route template
+	r.Use(middleware.Telemetry())
+
 	// Demo mode middleware (sets is_demo context on all requests when enabled)
 	r.Use(middleware.DemoMode())
 
@@ -299,6 +398,10 @@ func main() {
 	i18nMW := middleware.NewI18nMiddleware()
 	r.Use(i18nMW.Handle())
 
+	// Reject forged state-changing requests from other sites; pages get the
+	// token from the template renderer
+	r.Use(middleware.CSRF())
+
 	// Configure larger multipart memory limit for large article content
 	r.MaxMultipartMemory = 128 << 20 // 128MB
 
@@ -333,46 +436,57 @@ func main() {
 	log.Println("🔌 Initializing plugin system...")
 	pluginHostOpts := []plugin.ProdHostAPIOption{}
 	if db != nil {
-		pluginHostOpts = append(pluginHostOpts, plugin.WithDB("default", db))
+		pluginHostOpts = append(pluginHostOpts, plugin.WithDB("default", db),
+			plugin.WithFeatureFlags(service.NewFeatureFlagService(db)))
 	}
-	if valkeyCache != nil {
-		pluginHostOpts = append(pluginHostOpts, plugin.WithCache(valkeyCache))
+	pluginHostOpts = append(pluginHostOpts, plugin.WithCache(initPluginCache(config.Get())))
+	if files := initPluginFiles(config.Get(), configDir); files != nil {
+		pluginHostOpts = append(pluginHostOpts, plugin.WithFileStore(files))
+	}
+	for name, policy := range pluginResourcePolicies() {
+		pluginHostOpts = append(pluginHostOpts, plugin.WithPluginResourcePolicy(name, policy))
 	}
 	pluginHost := plugin.NewProdHostAPI(pluginHostOpts...)
 	pluginMgr := plugin.NewManager(pluginHost)
+	pluginMgr.SetBreakerPolicy(pluginBreakerPolicy(config.Get()))
+	if db != nil {
+		pluginMgr.SetSchemaMigrator(plugin.NewSchemaMigrator(db, nil)) // Plugin-owned tables from manifest migrations
+	}
+	// Push plugin state changes to /api/v1/events/stream subscribers
+	pluginMgr.SetLifecycleListener(func(name, event string) {
+		events.Publish(events.Event{Type: "plugin." + event, Plugin: name})
+	})
 	// Wire PluginManager back to HostAPI for plugin-to-plugin calls
 	pluginHost.PluginManager = pluginMgr
 	api.SetPluginManager(pluginMgr)
 	plugin.SetTemplatePluginManager(pluginMgr) // Enable {% use %} template tag
 	templateOverrides := plugin.NewTemplateOverrideRegistry(pluginMgr)
+	if err := templateOverrides.SetTemplateDir(templateDir); err != nil {
+		log.Printf("⚠️  Plugin block overrides disabled (dir=%s): %v", templateDir, err)
+	}
+	// Tell open pages rendered from a template when a plugin changes its override
+	templateOverrides.SetChangeListener(func(name string, templates []string) {
+		events.Publish(events.Event{Type: events.TypePluginTemplates, Plugin: name, Data: map[string]any{"templates": templates}})
+	})
 	plugin.SetTemplateOverrides(templateOverrides)
 	shared.SetTemplateOverrideProvider(templateOverrides) // Enable template overrides
 
-	// Register built-in example plugin (for development/testing)
-	helloPlugin := example.NewHelloPlugin()
-	if err := pluginMgr.Register(context.Background(), helloPlugin); err != nil {
-		log.Printf("⚠️  Failed to register hello plugin: %v", err)
-	} else {
-		log.Printf("✅ Plugin registered: %s v%s", helloPlugin.GKRegister().Name, helloPlugin.GKRegister().Version)
-	}
-
-	// Register core dashboard widgets plugin
-	dashboardPlugin := core.NewDashboardPlugin()
-	if err := pluginMgr.Register(context.Background(), dashboardPlugin); err != nil {
-		log.Printf("⚠️  Failed to register dashboard-core plugin: %v", err)
-	} else {
-		log.Printf("✅ Plugin registered: %s v%s", dashboardPlugin.GKRegister().Name, dashboardPlugin.GKRegister().Version)
+	// Register built-in plugins
+	for _, p := range builtinPlugins() {
+		manifest := p.GKRegister()
+		if err := pluginMgr.Register(context.Background(), p); err != nil {
+			log.Printf("⚠️  Failed to register %s plugin: %v", manifest.Name, err)
+		} else {
+			log.Printf("✅ Plugin registered: %s v%s", manifest.Name, manifest.Version)
+		}
 	}
 
 	// Load WASM plugins from plugins directory
-	pluginDir := os.Getenv("PLUGIN_DIR")
-	if pluginDir == "" {
-		pluginDir = filepath.Join(configDir, "plugins")
-	}
+	pluginDir := pluginDirectory(configDir)
 	api.SetPluginDir(pluginDir) // Enable plugin uploads
 
 	// Configure loader options
-	var loaderOpts []pluginloader.LoaderOption
+	loaderOpts := []pluginloader.LoaderOption{pluginloader.WithWASMOptions(pluginWASMOptions(config.Get())...)}
 	if os.Getenv("GOATFLOW_PLUGIN_LAZY_LOAD") == "true" {
 		loaderOpts = append(loaderOpts, pluginloader.WithLazyLoading())
 	}
@@ -396,6 +510,8 @@ func main() {
 	if os.Getenv("GOATFLOW_PLUGIN_HOT_RELOAD") == "true" || os.Getenv("GOATFLOW_ENV") == "development" {
 		if err := pluginLoader.WatchDir(context.Background()); err != nil {
 			log.Printf("⚠️  Plugin hot reload disabled: %v", err)
+		} else {
+			shared.SetTemplateHotReload(true)
 		}
 	}
 
@@ -430,6 +546,23 @@ func main() {
 		log.Printf("⚠️  Skipping dynamic modules (db unavailable: %v)", dbErr)
 	}
 
+	// Scope audit: every /api/v1 route reachable with an API token must declare a scope
+	scopeValidation := "warn"
+	if cfg := config.Get(); cfg != nil && cfg.Auth.APITokens.ScopeValidation != "" {
+		scopeValidation = strings.ToLower(cfg.Auth.APITokens.ScopeValidation)
+	}
+	if scopeValidation != "off" {
+		issues := routing.ValidateRouteScopes(routing.Sitemap())
+		for _, issue := range issues {
+			log.Printf("⚠️  Scope audit: %s", issue)
+		}
+		if len(issues) > 0 && scopeValidation == "strict" {
+			log.Fatalf("🚨 Scope audit failed: %d API route(s) without a valid scope", len(issues))
+		} else if len(issues) == 0 {
+			log.Printf("✅ Scope audit passed: all API token routes declare a scope")
+		}
+	}
+
 	// Runtime audit: verify critical API endpoints were registered (multi-doc safety)
 	func() {
 		needed := []string{"/api/v1/states", "/api/lookups/statuses", "/api/lookups/queues"}
@@ -494,6 +627,9 @@ func main() {
 
 	log.Println("✅ Backend initialized successfully")
 
+	initCoordination(db, config.Get())
+	stopReadReplicas := startReadReplicas(config.Get())
+
 	var schedulerCancel context.CancelFunc
 	if dbErr != nil || db == nil {
 		log.Printf("scheduler: disabled (database unavailable: %v)", dbErr)
@@ -507,7 +643,7 @@ func main() {
 				loc = tz
 			}
 		}
-		options := []scheduler.Option{scheduler.WithLocation(loc)}
+		options := []scheduler.Option{scheduler.WithLocation(loc), scheduler.WithTicketIndexer(api.TicketSearchIndexer())}
 		if emailHandler != nil {
 			options = append(options, scheduler.WithEmailHandler(emailHandler))
 		}
@@ -518,22 +654,56 @@ func main() {
 		if len(jobs) > 0 {
 			options = append(options, scheduler.WithJobs(jobs))
 		}
-		sched := scheduler.NewService(db, options...)
 
-		// Register plugin jobs with the scheduler
-		if pluginJobCount := plugin.RegisterPluginJobs(pluginMgr, sched); pluginJobCount > 0 {
-			log.Printf("✅ Registered %d plugin job(s) with scheduler", pluginJobCount)
+		// Only the elected leader runs the scheduler, so cron jobs and mail
+		// fetchers do not run once per replica. A new leader builds a fresh
+		// scheduler.
+		var lease time.Duration
+		if cfg != nil {
+			lease = cfg.Coordination.Lease
 		}
-
+		elector := coordination.NewElector(coordination.Default(), "scheduler", lease)
 		ctx, cancel := context.WithCancel(context.Background())
 		schedulerCancel = cancel
-		go func() {
+		go elector.Run(ctx, func(ctx context.Context) {
+			sched := scheduler.NewService(db, options...)
+
+			// Register plugin jobs with the scheduler
+			if pluginJobCount := plugin.RegisterPluginJobs(pluginMgr, sched); pluginJobCount > 0 {
+				log.Printf("✅ Registered %d plugin job(s) with scheduler", pluginJobCount)
+			}
+
+			log.Println("scheduler: background job runner started")
 			if err := sched.Run(ctx); err != nil {
 				log.Printf("scheduler: stopped: %v", err)
 			}
-		}()
-		log.Println("scheduler: background job runner started")
+		})
+		log.Println("scheduler: waiting for leader election")
 	}
+	// Background job queue workers
+	stopJobQueue := startJobQueue(db, config.Get())
+	// Imported language packs, reloaded when changed on another instance
+	stopLanguagePacks := startLanguagePacks(db)
+	// Flush opt-in usage telemetry and follow the admin's setting
+	stopTelemetry := startTelemetry(db)
+	// Store plugin log entries for the per-plugin log history
+	stopPluginLogs := startPluginLogs(db)
+
+	// gRPC ticket ingestion API on its own port
+	var grpcServer *grpcapi.Server
+	if cfg := config.Get(); cfg != nil && cfg.Server.GRPC.Enabled {
+		if dbErr != nil || db == nil {
+			log.Printf("grpc: disabled (database unavailable: %v)", dbErr)
+		} else {
+			grpcServer = startGRPCServer(db, &cfg.Server.GRPC)
+		}
+	}
+	// Push queue counters to /api/v1/events/stream subscribers
+	if db != nil {
+		go events.NewQueueCountPublisher(db, events.Default(), 10*time.Second).Run(context.Background())
+	}
+	// Drop ticket viewers whose heartbeat has lapsed
+	go events.DefaultPresence().Run(context.Background())
 	// Ensure /api/v1 i18n endpoints are registered (after YAML so we can augment)
 	v1Group := r.Group("/api/v1")
 	i18nHandlers := api.NewI18nHandlers()
@@ -552,6 +722,9 @@ func main() {
 
 	// Start server
 	port := os.Getenv("APP_PORT")
+	if port == "" && serving && serve.configFile != "" {
+		port = strconv.Itoa(config.Get().Server.Port)
+	}
 	if port == "" {
 		port = "8080"
 	}
@@ -572,27 +745,85 @@ func main() {
 	fmt.Println("  POST /api/v1/ldap/sync/users -> Sync users")
 	fmt.Println("  GET  /api/v1/ldap/config -> Get LDAP config")
 
-	if err := r.Run(":" + port); err != nil {
+	// Stop accepting requests on SIGINT/SIGTERM and let in-flight ones finish
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	var serveErr error
+	if ln, err := net.Listen("tcp", ":"+port); err != nil {
+		serveErr = err
+	} else {
+		timeout := serve.drainTimeout(config.Get())
+		serveErr = serveHTTP(&http.Server{Handler: r, ReadHeaderTimeout: 30 * time.Second}, ln, signals, timeout, reloadConfig)
+	}
+	signal.Stop(signals)
+	if serveErr != nil && !errors.Is(serveErr, http.ErrServerClosed) {
 		if schedulerCancel != nil {
 			schedulerCancel()
 		}
+		if grpcServer != nil {
+			grpcServer.Stop()
+		}
+		stopJobQueue()
+		stopLanguagePacks()
+		stopTelemetry()
+		stopReadReplicas()
 		// Stop plugin hot reload watcher
 		pluginLoader.StopWatch()
 		// Shutdown plugins gracefully
 		if err := pluginMgr.ShutdownAll(context.Background()); err != nil {
 			log.Printf("⚠️  Plugin shutdown error: %v", err)
 		}
-		log.Fatalf("server failed: %v", err)
+		stopPluginLogs()
+		shutdownTracing(tracer)
+		log.Fatalf("server failed: %v", serveErr)
 	}
 	if schedulerCancel != nil {
 		schedulerCancel()
 	}
+	if grpcServer != nil {
+		grpcServer.Stop()
+	}
+	stopJobQueue()
+	stopLanguagePacks()
+	stopTelemetry()
+	stopReadReplicas()
 	// Stop plugin hot reload watcher
 	pluginLoader.StopWatch()
 	// Shutdown plugins gracefully
 	if err := pluginMgr.ShutdownAll(context.Background()); err != nil {
 		log.Printf("⚠️  Plugin shutdown error: %v", err)
 	}
+	stopPluginLogs()
+}
+
+// startGRPCServer serves the gRPC ticket ingestion API in the background.
+// It returns nil when the server cannot be set up.
+func startGRPCServer(db *sql.DB, cfg *config.GRPCConfig) *grpcapi.Server {
+	srv, err := grpcapi.NewServer(db, service.NewAPITokenService(db), grpcapi.Options{
+		MaxMessageBytes: cfg.MaxMessageBytes,
+		TLSCertFile:     cfg.TLSCertFile,
+		TLSKeyFile:      cfg.TLSKeyFile,
+	})
+	if err != nil {
+		log.Printf("grpc: disabled: %v", err)
+		return nil
+	}
+	port := cfg.Port
+	if port == 0 {
+		port = 50051
+	}
+	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		log.Printf("grpc: disabled: %v", err)
+		return nil
+	}
+	go func() {
+		if err := srv.Serve(lis); err != nil {
+			log.Printf("grpc: stopped: %v", err)
+		}
+	}()
+	log.Printf("grpc: ticket ingestion API listening on :%d", port)
+	return srv
 }
 
 func initValkeyCache(cfg *config.Config) *cache.RedisCache {
@@ -630,6 +861,349 @@ func initValkeyCache(cfg *config.Config) *cache.RedisCache {
 	return cacheClient
 }
 
+// initCoordination sets the locker used for leader election between
+// replicas. It falls back to process memory, which is only safe for a
+// single instance, when the configured backend is unavailable.
+func initCoordination(db *sql.DB, cfg *config.Config) {
+	backend := "database"
+	if cfg != nil && cfg.Coordination.Backend != "" {
+		backend = cfg.Coordination.Backend
+	}
+	switch {
+	case backend == "database" && db != nil:
+		coordination.SetDefault(coordination.NewDBLocker(db))
+	case backend == "redis" && cfg.Valkey.Host != "" && cfg.Valkey.Port != 0:
+		coordination.SetDefault(coordination.NewRedisLocker(redis.NewClient(&redis.Options{
+			Addr:     cfg.Valkey.GetValkeyAddr(),
+			Password: cfg.Valkey.Password,
+			DB:       cfg.Valkey.DB,
+		}), cfg.Coordination.KeyPrefix))
+	case backend == "memory":
+		coordination.SetDefault(coordination.NewMemoryLocker())
+	default:
+		log.Printf("coordination: %s backend unavailable, coordinating in memory (single instance only)", backend)
+		coordination.SetDefault(coordination.NewMemoryLocker())
+	}
+}
+
+// startReadReplicas opens the configured read replicas and checks their
+// health and lag in the background, so database.GetReadDB can route
+// read-only queries to them.
+func startReadReplicas(cfg *config.Config) func() {
+	if cfg == nil || len(cfg.Database.Replicas) == 0 {
+		return func() {}
+	}
+	driver := database.GetDBDriver()
+	var replicas []database.Replica
+	for i, rc := range cfg.Database.Replicas {
+		name := rc.Name
+		if name == "" {
+			name = fmt.Sprintf("replica-%d", i+1)
+		}
+		db, err := database.OpenReplica(driver, database.ReplicaConfig{
+			Name:     name,
+			Host:     rc.Host,
+			Port:     rc.Port,
+			User:     rc.User,
+			Password: rc.Password,
+			Database: rc.Database,
+			SSLMode:  rc.SSLMode,
+		})
+		if err != nil {
+			log.Printf("database: replica %s skipped: %v", name, err)
+			continue
+		}
+		if cfg.Database.MaxOpenConns > 0 {
+			db.SetMaxOpenConns(cfg.Database.MaxOpenConns)
+		}
+		if cfg.Database.MaxIdleConns > 0 {
+			db.SetMaxIdleConns(cfg.Database.MaxIdleConns)
+		}
+		if cfg.Database.ConnMaxLifetime > 0 {
+			db.SetConnMaxLifetime(cfg.Database.ConnMaxLifetime)
+		}
+		replicas = append(replicas, database.Replica{Name: name, DB: db})
+	}
+	if len(replicas) == 0 {
+		return func() {}
+	}
+
+	set := database.NewReplicaSet(replicas, cfg.Database.ReplicaMaxLag, nil)
+	database.SetReplicas(set)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		set.Run(ctx, cfg.Database.ReplicaCheckInterval)
+	}()
+	log.Printf("database: routing reads to %d replica(s)", len(replicas))
+	return func() {
+		cancel()
+		<-done
+		database.SetReplicas(nil)
+		_ = set.Close()
+	}
+}
+
+// initPluginCache returns the store behind the plugin cache host functions:
+// Valkey when configured and reachable, so every instance sees the same
+// values, and process memory otherwise.
+func initPluginCache(cfg *config.Config) plugin.CacheStore {
+	if cfg == nil {
+		return plugin.NewMemoryCacheStore(0, 0)
+	}
+	pc := cfg.Plugins.Cache
+	if pc.Backend != "memory" {
+		if valkeyCache != nil {
+			return plugin.NewRedisCacheStore(redis.NewClient(&redis.Options{
+				Addr:     cfg.Valkey.GetValkeyAddr(),
+				Password: cfg.Valkey.Password,
+				DB:       cfg.Valkey.DB,
+			}), pc.KeyPrefix)
+		}
+		log.Printf("plugin cache: valkey not configured, caching in memory")
+	}
+	store := plugin.NewMemoryCacheStore(pc.MaxEntries, pc.MaxBytes)
+	plugin.RegisterCacheStoreMetrics(store)
+	return store
+}
+
+// localStoragePath returns the directory of the local attachment storage.
+func localStoragePath(cfg *config.Config, configDir string) string {
+	if path := os.Getenv("STORAGE_PATH"); path != "" {
+		return path
+	}
+	if cfg != nil && cfg.Storage.Local.Path != "" {
+		return cfg.Storage.Local.Path
+	}
+	return filepath.Join(configDir, "storage")
+}
+
+// initPluginFiles returns the store behind the plugin file functions, or
+// nil when the storage cannot be used. Plugin files live in the local
+// attachment storage under plugins/<name>/, also when attachments are kept
+// in the database, which only holds article attachments.
+func initPluginFiles(cfg *config.Config, configDir string) plugin.FileStore {
+	storage, err := service.NewLocalStorageService(localStoragePath(cfg, configDir))
+	if err != nil {
+		log.Printf("plugin files: local storage init failed: %v", err)
+		return nil
+	}
+	return service.NewPluginFileStore(storage)
+}
+
+// pluginBreakerPolicy returns the circuit breaker policy for plugin calls.
+// Without a configuration the plugin package defaults apply.
+func pluginBreakerPolicy(cfg *config.Config) plugin.BreakerPolicy {
+	if cfg == nil {
+		return plugin.DefaultBreakerPolicy()
+	}
+	cb := cfg.Plugins.CircuitBreaker
+	return plugin.BreakerPolicy{
+		Disabled:    !cb.Enabled,
+		Window:      cb.Window,
+		MinCalls:    cb.MinCalls,
+		FailureRate: cb.FailureRate,
+		OpenFor:     cb.OpenFor,
+	}
+}
+
+// pluginWASMOptions returns the load options of WASM plugins from the
+// configuration. Without one the wasm package defaults apply.
+func pluginWASMOptions(cfg *config.Config) []wasm.LoadOption {
+	if cfg == nil || cfg.Plugins.WASMPool.MaxInstances <= 0 {
+		return nil
+	}
+	pool := cfg.Plugins.WASMPool
+	return []wasm.LoadOption{wasm.WithInstancePool(pool.MinInstances, pool.MaxInstances)}
+}
+
+// startJobQueue starts the background job workers and makes the queue
+// available through jobqueue.Default. The returned function stops the
+// workers once their running jobs are done.
+func startJobQueue(db *sql.DB, cfg *config.Config) func() {
+	if cfg == nil || !cfg.Jobs.Enabled {
+		return func() {}
+	}
+	jc := cfg.Jobs
+	if jc.Driver == "" {
+		jc.Driver = "database"
+	}
+	var driver jobqueue.Driver
+	switch jc.Driver {
+	case "database":
+		if db == nil {
+			log.Println("jobqueue: disabled (database unavailable)")
+			return func() {}
+		}
+		driver = jobqueue.NewDBDriver(db)
+	case "redis":
+		if cfg.Valkey.Host == "" || cfg.Valkey.Port == 0 {
+			log.Println("jobqueue: disabled (redis driver needs valkey.host and valkey.port)")
+			return func() {}
+		}
+		driver = jobqueue.NewRedisDriver(redis.NewClient(&redis.Options{
+			Addr:     cfg.Valkey.GetValkeyAddr(),
+			Password: cfg.Valkey.Password,
+			DB:       cfg.Valkey.DB,
+		}), jc.KeyPrefix)
+	default:
+		log.Printf("jobqueue: disabled (unknown driver %q)", jc.Driver)
+		return func() {}
+	}
+
+	q := jobqueue.New(driver, jobqueue.Options{
+		Workers:      jc.Workers,
+		Queues:       jc.Queues,
+		PollInterval: jc.PollInterval,
+		Lease:        jc.Lease,
+		MaxAttempts:  jc.MaxAttempts,
+	})
+	jobqueue.SetDefault(q)
+	if db != nil {
+		q.Handle(service.PrivacyJobType, service.NewPrivacyService(db).HandleJob)
+		q.Handle(service.BackupJobType, service.NewBackupService(db).HandleJob)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = q.Run(ctx)
+	}()
+	log.Printf("jobqueue: workers started (%s driver)", jc.Driver)
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// startLanguagePacks applies the imported language packs and keeps them in
+// sync with the database. The returned function stops the watcher.
+func startLanguagePacks(db *sql.DB) func() {
+	if db == nil {
+		return func() {}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		service.NewLanguagePackService(db).Watch(ctx, service.LanguagePackReloadInterval)
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// startTelemetry applies the telemetry setting and periodically stores the
+// usage counted by this instance. The returned function stores what is
+// left and stops.
+func startTelemetry(db *sql.DB) func() {
+	if db == nil {
+		return func() {}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		service.NewTelemetryService(db).Watch(ctx, service.TelemetryFlushInterval)
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+// startPluginLogs stores the entries plugins log in the database, so their
+// history outlives the in-memory buffer. The returned function stores what
+// is left and stops.
+func startPluginLogs(db *sql.DB) func() {
+	if db == nil {
+		return func() {}
+	}
+	entries, unsubscribe := plugin.GetLogBuffer().Subscribe(4 * service.PluginLogBatchSize)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		service.NewPluginLogService(db).Watch(context.Background(), entries, service.PluginLogFlushInterval)
+	}()
+	return func() {
+		unsubscribe()
+		<-done
+	}
+}
+
+// initTracing installs the OpenTelemetry tracer when
+// metrics.opentelemetry.enabled is set. The endpoint falls back to the
+// standard OTEL_EXPORTER_OTLP_ENDPOINT variable.
+func initTracing(cfg *config.Config) *tracing.Tracer {
+	if cfg == nil || !cfg.Metrics.OpenTelemetry.Enabled {
+		return nil
+	}
+	otel := cfg.Metrics.OpenTelemetry
+	endpoint := otel.Endpoint
+	if endpoint == "" {
+		endpoint = os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+	}
+	tracer, err := tracing.Setup(tracing.Config{
+		Enabled:        true,
+		Endpoint:       endpoint,
+		Headers:        otel.Headers,
+		ServiceName:    otel.ServiceName,
+		ServiceVersion: version.Version,
+		Ratio:          otel.TraceRatio,
+	})
+	if err != nil {
+		log.Printf("tracing disabled: %v", err)
+		return nil
+	}
+	log.Printf("tracing: exporting %.0f%% of new traces via OTLP/HTTP", otel.TraceRatio*100)
+	return tracer
+}
+
+// shutdownTracing sends the spans still queued for export.
+func shutdownTracing(tracer *tracing.Tracer) {
+	if tracer == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := tracer.Shutdown(ctx); err != nil {
+		log.Printf("tracing shutdown: %v", err)
+	}
+}
+
+// initPermissionCache turns on caching of group permissions unless it is
+// disabled in the config.
+func initPermissionCache(cfg *config.Config) {
+	if cfg == nil || !cfg.Auth.PermissionCache.Enabled {
+		return
+	}
+	opts := services.PermissionCacheOptions{TTL: cfg.Auth.PermissionCache.TTL}
+	if cfg.Auth.PermissionCache.Backend == "valkey" {
+		if valkeyCache != nil {
+			opts.Shared = valkeyCache
+		} else {
+			log.Printf("permission cache: valkey not configured, caching in memory")
+		}
+	}
+	services.EnablePermissionCache(opts)
+}
+
+// initStatementCache applies the prepared statement cache and slow query
+// settings to all database connections, including those already open.
+func initStatementCache(cfg *config.Config) {
+	if cfg == nil {
+		return
+	}
+	stmtcache.Configure(stmtcache.Options{
+		Size:          cfg.Database.StatementCacheSize,
+		SlowThreshold: cfg.Database.SlowQueryThreshold,
+		Explain:       cfg.App.Debug,
+	})
+}
+
 // runRunner starts the background task runner.
 func runRunner(db *sql.DB) {
 	log.Println("Starting GoatFlow background task runner...")
@@ -651,6 +1225,34 @@ func runRunner(db *sql.DB) {
 	sessionCleanupTask := tasks.NewSessionCleanupTask(db)
 	registry.Register(sessionCleanupTask)
 
+	// Register scheduled report delivery task
+	reportTask := tasks.NewReportScheduleTask(db, &emailCfg.Email)
+	registry.Register(reportTask)
+
+	// Register expired ticket lock release task
+	unlockTask := tasks.NewTicketUnlockTask(db)
+	registry.Register(unlockTask)
+
+	// Register expired Idempotency-Key cleanup task
+	idempotencyTask := tasks.NewIdempotencyCleanupTask(db)
+	registry.Register(idempotencyTask)
+
+	// Register captured API request cleanup task
+	captureTask := tasks.NewRequestCaptureCleanupTask(db, &emailCfg.Server.RequestCapture)
+	registry.Register(captureTask)
+
+	// Register article compression task
+	compressTask := tasks.NewArticleCompressionTask(db, &emailCfg.Storage.Compression)
+	registry.Register(compressTask)
+
+	// Register LDAP directory sync task
+	ldapSyncTask := tasks.NewLDAPSyncTask(db, &emailCfg.Auth)
+	registry.Register(ldapSyncTask)
+
+	// Register expired article draft cleanup task
+	draftCleanupTask := tasks.NewArticleDraftCleanupTask(db)
+	registry.Register(draftCleanupTask)
+
 	log.Printf("Registered %d background tasks", len(registry.All()))
 
 	// Create and start runner
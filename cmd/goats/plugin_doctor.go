@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/goatkit/goatflow/internal/config"
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/plugin"
+	"github.com/goatkit/goatflow/internal/plugin/core"
+	"github.com/goatkit/goatflow/internal/plugin/example"
+	"github.com/goatkit/goatflow/internal/plugin/wasm"
+)
+
+// builtinPlugins returns the plugins compiled into the server.
+func builtinPlugins() []plugin.Plugin {
+	return []plugin.Plugin{
+		example.NewHelloPlugin(),  // Example plugin (for development/testing)
+		core.NewDashboardPlugin(), // Core dashboard widgets
+	}
+}
+
+// pluginDirectory returns the directory WASM plugins are loaded from.
+func pluginDirectory(configDir string) string {
+	if dir := os.Getenv("PLUGIN_DIR"); dir != "" {
+		return dir
+	}
+	return filepath.Join(configDir, "plugins")
+}
+
+// pluginResourcePolicies returns the per-plugin resource policies from the
+// environment and config. Plugins listed in GOATFLOW_PLUGIN_ISOLATED_WIDGETS
+// render their widgets in a sandboxed iframe instead of inline; plugins under
+// plugins.public_routes may serve routes marked public without login.
+func pluginResourcePolicies() map[string]plugin.ResourcePolicy {
+	policies := make(map[string]plugin.ResourcePolicy)
+	for _, name := range strings.Split(os.Getenv("GOATFLOW_PLUGIN_ISOLATED_WIDGETS"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			policies[name] = plugin.ResourcePolicy{WidgetIsolation: plugin.WidgetIsolationIframe}
+		}
+	}
+	if cfg := config.Get(); cfg != nil {
+		for name, grant := range cfg.Plugins.PublicRoutes {
+			allowIPs, err := models.NormalizeAllowedIPs(grant.AllowIPs)
+			if err != nil {
+				log.Printf("⚠️ Not granting public routes to plugin %s: %v", name, err)
+				continue
+			}
+			policy := policies[name]
+			policy.PublicRoutes = &plugin.PublicRoutePolicy{AllowIPs: allowIPs, RateLimit: grant.RateLimit}
+			policies[name] = policy
+		}
+	}
+	return policies
+}
+
+// runCommand runs a maintenance command instead of the server and returns
+// the process exit code.
+func runCommand(db *sql.DB, configDir string, args []string) int {
+	if len(args) >= 2 && args[0] == "plugin" && args[1] == "doctor" {
+		return runPluginDoctor(db, configDir, args[2:], os.Stdout)
+	}
+	fmt.Fprintf(os.Stderr, "Unknown command: %s\n", strings.Join(args, " "))
+	fmt.Fprintln(os.Stderr, "Commands:")
+	fmt.Fprintln(os.Stderr, "  plugin doctor [--fix] [--json]   Check plugin files, sysconfig flags, policies and schemas for orphans")
+	fmt.Fprintln(os.Stderr, "  serve [--config FILE]            Start the server from a config file (see serve --help)")
+	return 2
+}
+
+// runPluginDoctor reconciles the plugins directory, sysconfig enabled flags,
+// resource policies and plugin schema records, reporting orphans. With --fix
+// it removes the issues that can be cleaned up without losing plugin data.
+// Exit code 0 means no issues remain, 1 that some do, 2 that the check failed.
+func runPluginDoctor(db *sql.DB, configDir string, args []string, out io.Writer) int {
+	fset := flag.NewFlagSet("plugin doctor", flag.ContinueOnError)
+	fix := fset.Bool("fix", false, "remove orphaned entries that can be cleaned up safely")
+	asJSON := fset.Bool("json", false, "print issues as JSON")
+	if err := fset.Parse(args); err != nil {
+		return 2
+	}
+	if db == nil {
+		fmt.Fprintln(os.Stderr, "plugin doctor: database connection required")
+		return 2
+	}
+
+	ctx := context.Background()
+	dir := pluginDirectory(configDir)
+	state := plugin.DoctorState{Files: collectPluginFiles(ctx, dir)}
+	for _, p := range builtinPlugins() {
+		state.Builtin = append(state.Builtin, p.GKRegister().Name)
+	}
+	for name := range pluginResourcePolicies() {
+		state.Policies = append(state.Policies, name)
+	}
+	sort.Strings(state.Policies)
+	if err := plugin.LoadDoctorDBState(ctx, db, &state); err != nil {
+		fmt.Fprintf(os.Stderr, "plugin doctor: %v\n", err)
+		return 2
+	}
+
+	issues := plugin.Diagnose(state)
+	if *fix {
+		repaired, err := plugin.RepairDoctorIssues(ctx, db, issues)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "plugin doctor: %v\n", err)
+			return 2
+		}
+		remaining := issues[:0]
+		for _, issue := range issues {
+			if !issue.Fixable {
+				remaining = append(remaining, issue)
+			}
+		}
+		issues = remaining
+		if !*asJSON {
+			fmt.Fprintf(out, "Repaired %d issue(s)\n", repaired)
+		}
+	}
+
+	if *asJSON {
+		if issues == nil {
+			issues = []plugin.DoctorIssue{}
+		}
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(issues)
+	} else {
+		printDoctorIssues(out, dir, len(state.Builtin)+len(state.Files), issues)
+	}
+	if len(issues) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// collectPluginFiles reads the manifest of every WASM file in dir without
+// registering the plugin, so no plugin code runs against the host.
+func collectPluginFiles(ctx context.Context, dir string) []plugin.DoctorFile {
+	var files []plugin.DoctorFile
+	_ = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || strings.ToLower(filepath.Ext(path)) != ".wasm" {
+			return nil
+		}
+		f := plugin.DoctorFile{Path: path}
+		p, err := wasm.LoadFromFile(ctx, path)
+		if err != nil {
+			f.Err = err
+		} else {
+			f.Manifest = p.GKRegister().Name
+			_ = p.Shutdown(ctx)
+		}
+		files = append(files, f)
+		return nil
+	})
+	return files
+}
+
+func printDoctorIssues(out io.Writer, dir string, checked int, issues []plugin.DoctorIssue) {
+	fmt.Fprintf(out, "Checked %d plugin(s) (built-in and %s)\n", checked, dir)
+	if len(issues) == 0 {
+		fmt.Fprintln(out, "✅ No plugin state issues found")
+		return
+	}
+	fixable := 0
+	for _, issue := range issues {
+		mark := " "
+		if issue.Fixable {
+			mark = "*"
+			fixable++
+		}
+		fmt.Fprintf(out, "%s %-15s %-20s %s\n", mark, issue.Kind, issue.Plugin, issue.Detail)
+	}
+	fmt.Fprintf(out, "⚠️  %d issue(s) found", len(issues))
+	if fixable > 0 {
+		fmt.Fprintf(out, "; %d marked * can be removed with --fix", fixable)
+	}
+	fmt.Fprintln(out)
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/goatkit/goatflow/internal/config"
+)
+
+// defaultShutdownTimeout applies when neither --shutdown-timeout nor
+// server.shutdown_timeout is set.
+const defaultShutdownTimeout = 30 * time.Second
+
+// serveOptions are the flags of `goats serve`.
+type serveOptions struct {
+	configFile      string
+	shutdownTimeout time.Duration
+	check           bool
+}
+
+// parseServeArgs parses the arguments after `serve`. The config file can
+// also be given in GOATFLOW_CONFIG.
+func parseServeArgs(args []string, out io.Writer) (serveOptions, error) {
+	var opts serveOptions
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	fs.SetOutput(out)
+	fs.StringVar(&opts.configFile, "config", os.Getenv("GOATFLOW_CONFIG"), "YAML or TOML config file")
+	fs.DurationVar(&opts.shutdownTimeout, "shutdown-timeout", 0, "how long in-flight requests may finish on shutdown (default server.shutdown_timeout)")
+	fs.BoolVar(&opts.check, "check", false, "validate the config file and exit")
+	fs.Usage = func() {
+		fmt.Fprintln(out, "Usage: goats serve [--config FILE] [--shutdown-timeout 30s] [--check]")
+		fmt.Fprintln(out)
+		fmt.Fprintln(out, "Starts the HTTP server and background workers. SIGHUP reloads the config file;")
+		fmt.Fprintln(out, "SIGINT and SIGTERM stop accepting requests and drain the in-flight ones.")
+		fmt.Fprintln(out)
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return opts, err
+	}
+	if fs.NArg() > 0 {
+		return opts, fmt.Errorf("unexpected argument %q", fs.Arg(0))
+	}
+	if opts.check && opts.configFile == "" {
+		return opts, errors.New("--check needs --config")
+	}
+	if opts.shutdownTimeout < 0 {
+		return opts, errors.New("--shutdown-timeout must not be negative")
+	}
+	return opts, nil
+}
+
+// drainTimeout returns the drain timeout: the flag, then the config,
+// then the default.
+func (o serveOptions) drainTimeout(cfg *config.Config) time.Duration {
+	if o.shutdownTimeout > 0 {
+		return o.shutdownTimeout
+	}
+	if cfg != nil && cfg.Server.ShutdownTimeout > 0 {
+		return cfg.Server.ShutdownTimeout
+	}
+	return defaultShutdownTimeout
+}
+
+// reloadConfig reloads the config file on SIGHUP. Settings read at startup
+// keep their values until the next restart.
+func reloadConfig() {
+	restart, err := config.Reload()
+	switch {
+	case errors.Is(err, config.ErrNoConfigFile):
+		log.Println("serve: SIGHUP ignored, start with --config to reload a config file")
+	case err != nil:
+		log.Printf("⚠️  Config reload failed, keeping the running config: %v", err)
+	case len(restart) > 0:
+		log.Printf("✅ Config reloaded; restart to apply changes to: %s", strings.Join(restart, ", "))
+	default:
+		log.Println("✅ Config reloaded")
+	}
+}
+
+// serveHTTP serves until a SIGINT or SIGTERM arrives on signals, then stops
+// accepting connections and waits up to timeout for in-flight requests.
+// Requests still running after that are cut off. SIGHUP calls reload.
+func serveHTTP(srv *http.Server, ln net.Listener, signals <-chan os.Signal, timeout time.Duration, reload func()) error {
+	errc := make(chan error, 1)
+	go func() { errc <- srv.Serve(ln) }()
+	for {
+		select {
+		case err := <-errc:
+			return err
+		case sig := <-signals:
+			if sig == syscall.SIGHUP {
+				if reload != nil {
+					reload()
+				}
+				continue
+			}
+			log.Printf("Received %v, draining requests (up to %s)...", sig, timeout)
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			err := srv.Shutdown(ctx)
+			cancel()
+			if errors.Is(err, context.DeadlineExceeded) {
+				log.Printf("⚠️  Requests still running after %s were cut off", timeout)
+				_ = srv.Close()
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			log.Println("✅ All requests finished")
+			return nil
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/config"
+)
+
+func TestParseServeArgs(t *testing.T) {
+	opts, err := parseServeArgs([]string{"--config", "goatflow.yaml", "--shutdown-timeout", "5s"}, io.Discard)
+	require.NoError(t, err)
+	assert.Equal(t, "goatflow.yaml", opts.configFile)
+	assert.Equal(t, 5*time.Second, opts.drainTimeout(nil))
+
+	t.Setenv("GOATFLOW_CONFIG", "/etc/goatflow.toml")
+	opts, err = parseServeArgs(nil, io.Discard)
+	require.NoError(t, err)
+	assert.Equal(t, "/etc/goatflow.toml", opts.configFile)
+
+	cfg := &config.Config{}
+	assert.Equal(t, defaultShutdownTimeout, opts.drainTimeout(cfg))
+	cfg.Server.ShutdownTimeout = 12 * time.Second
+	assert.Equal(t, 12*time.Second, opts.drainTimeout(cfg))
+
+	t.Setenv("GOATFLOW_CONFIG", "")
+	_, err = parseServeArgs([]string{"--check"}, io.Discard)
+	assert.Error(t, err, "--check needs a config file")
+	_, err = parseServeArgs([]string{"now"}, io.Discard)
+	assert.Error(t, err)
+}
+
+func TestServeHTTPDrainsRequests(t *testing.T) {
+	started := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(200 * time.Millisecond)
+		_, _ = w.Write([]byte("done"))
+	})
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	signals := make(chan os.Signal, 1)
+	reloads := 0
+	done := make(chan error, 1)
+	go func() {
+		done <- serveHTTP(&http.Server{Handler: handler}, ln, signals, 5*time.Second, func() { reloads++ })
+	}()
+
+	body := make(chan string, 1)
+	go func() {
+		resp, err := http.Get("http://" + ln.Addr().String())
+		if err != nil {
+			body <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		body <- string(data)
+	}()
+	<-started
+	signals <- syscall.SIGHUP
+	signals <- syscall.SIGTERM
+
+	assert.Equal(t, "done", <-body, "the in-flight request finishes")
+	require.NoError(t, <-done)
+	assert.Equal(t, 1, reloads)
+	_, err = net.DialTimeout("tcp", ln.Addr().String(), time.Second)
+	assert.Error(t, err, "no new connections after shutdown")
+}
+
+func TestServeHTTPCutsOffAfterTimeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	started := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	})
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	signals := make(chan os.Signal, 1)
+	done := make(chan error, 1)
+	go func() { done <- serveHTTP(&http.Server{Handler: handler}, ln, signals, 50*time.Millisecond, nil) }()
+	go func() {
+		if resp, err := http.Get("http://" + ln.Addr().String()); err == nil {
+			resp.Body.Close()
+		}
+	}()
+	<-started
+	signals <- os.Interrupt
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("serveHTTP did not return after the shutdown timeout")
+	}
+}
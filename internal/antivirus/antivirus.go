@@ -0,0 +1,111 @@
+// Package antivirus scans attachment content for malware before it is
+// stored.
+//
+// A Scanner checks content and reports it clean or infected. ClamdScanner
+// talks to a ClamAV daemon; further drivers can implement Scanner. The
+// scanner configured for the application is set with SetDefault; Default
+// returns nil when scanning is off.
+package antivirus
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/goatkit/goatflow/internal/config"
+)
+
+// Status is the outcome of a scan.
+type Status string
+
+const (
+	StatusClean    Status = "clean"
+	StatusInfected Status = "infected"
+)
+
+// Result is the outcome of scanning one file.
+type Result struct {
+	Status    Status `json:"status"`
+	Signature string `json:"signature,omitempty"` // Name of the malware found
+}
+
+// Infected reports whether the scan found malware.
+func (r *Result) Infected() bool {
+	return r != nil && r.Status == StatusInfected
+}
+
+// Scanner scans content for malware.
+type Scanner interface {
+	// Name identifies the scanner in scan records, such as "clamd".
+	Name() string
+	// Scan reads r to the end and reports what it found. An error means
+	// the content could not be scanned, not that it is infected.
+	Scan(ctx context.Context, r io.Reader) (*Result, error)
+	// Ping checks that the scanner can be reached and returns its version.
+	Ping(ctx context.Context) (string, error)
+}
+
+var defaultScanner atomic.Pointer[Scanner]
+
+// SetDefault makes s the scanner returned by Default. A nil s turns
+// scanning off.
+func SetDefault(s Scanner) {
+	if s == nil {
+		defaultScanner.Store(nil)
+		return
+	}
+	defaultScanner.Store(&s)
+}
+
+// Default returns the application's scanner, or nil when attachments are
+// not scanned.
+func Default() Scanner {
+	if s := defaultScanner.Load(); s != nil {
+		return *s
+	}
+	return nil
+}
+
+// NewFromConfig returns the scanner the configuration selects, or nil when
+// scanning is disabled.
+func NewFromConfig(cfg config.AttachmentScanConfig) (Scanner, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	switch strings.ToLower(cfg.Driver) {
+	case "", "clamd":
+		network, address, err := ParseAddress(cfg.Address)
+		if err != nil {
+			return nil, err
+		}
+		timeout := cfg.Timeout
+		if timeout <= 0 {
+			timeout = 30 * time.Second
+		}
+		return NewClamdScanner(network, address, timeout), nil
+	default:
+		return nil, fmt.Errorf("unknown antivirus driver %q", cfg.Driver)
+	}
+}
+
+// ParseAddress splits a scanner address such as "tcp://clamav:3310" or
+// "unix:///run/clamav/clamd.ctl" into network and address. An address
+// without a scheme is a TCP host:port.
+func ParseAddress(addr string) (network, address string, err error) {
+	addr = strings.TrimSpace(addr)
+	switch {
+	case addr == "":
+		return "", "", fmt.Errorf("antivirus address is empty")
+	case strings.HasPrefix(addr, "unix://"):
+		return "unix", strings.TrimPrefix(addr, "unix://"), nil
+	case strings.HasPrefix(addr, "tcp://"):
+		return "tcp", strings.TrimPrefix(addr, "tcp://"), nil
+	case strings.Contains(addr, "://"):
+		return "", "", fmt.Errorf("unsupported antivirus address %q", addr)
+	default:
+		return "tcp", addr, nil
+	}
+}
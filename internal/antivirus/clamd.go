@@ -0,0 +1,146 @@
+package antivirus
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+// clamdChunkSize is the size of the chunks content is streamed in. clamd
+// rejects streams longer than its StreamMaxLength.
+const clamdChunkSize = 64 * 1024
+
+// ClamdScanner scans content with a ClamAV daemon using the INSTREAM
+// command.
+type ClamdScanner struct {
+	network string
+	address string
+	timeout time.Duration
+}
+
+// NewClamdScanner returns a scanner for the clamd listening on network
+// ("tcp" or "unix") and address. timeout bounds each scan.
+func NewClamdScanner(network, address string, timeout time.Duration) *ClamdScanner {
+	return &ClamdScanner{network: network, address: address, timeout: timeout}
+}
+
+// Name implements Scanner.
+func (s *ClamdScanner) Name() string { return "clamd" }
+
+// Scan implements Scanner.
+func (s *ClamdScanner) Scan(ctx context.Context, r io.Reader) (*Result, error) {
+	conn, err := s.dial(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if err := streamToClamd(conn, r); err != nil {
+		// clamd closes the connection once a stream exceeds its limit;
+		// its reply says why.
+		if reply, rerr := readClamdReply(conn); rerr == nil && reply != "" {
+			return parseClamdReply(reply)
+		}
+		return nil, err
+	}
+
+	reply, err := readClamdReply(conn)
+	if err != nil {
+		return nil, err
+	}
+	return parseClamdReply(reply)
+}
+
+// Ping implements Scanner, returning the clamd version.
+func (s *ClamdScanner) Ping(ctx context.Context) (string, error) {
+	conn, err := s.dial(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte("zVERSION\x00")); err != nil {
+		return "", fmt.Errorf("clamd: %w", err)
+	}
+	return readClamdReply(conn)
+}
+
+// streamToClamd sends the INSTREAM command with r's content in chunks.
+func streamToClamd(conn net.Conn, r io.Reader) error {
+	w := bufio.NewWriterSize(conn, clamdChunkSize+4)
+	if _, err := w.WriteString("zINSTREAM\x00"); err != nil {
+		return fmt.Errorf("clamd: %w", err)
+	}
+	buf := make([]byte, clamdChunkSize)
+	var size [4]byte
+	for {
+		n, rerr := io.ReadFull(r, buf)
+		if n > 0 {
+			binary.BigEndian.PutUint32(size[:], uint32(n))
+			if _, err := w.Write(size[:]); err != nil {
+				return fmt.Errorf("clamd: %w", err)
+			}
+			if _, err := w.Write(buf[:n]); err != nil {
+				return fmt.Errorf("clamd: %w", err)
+			}
+		}
+		if rerr == io.EOF || rerr == io.ErrUnexpectedEOF {
+			break
+		}
+		if rerr != nil {
+			return fmt.Errorf("clamd: read content: %w", rerr)
+		}
+	}
+	binary.BigEndian.PutUint32(size[:], 0)
+	if _, err := w.Write(size[:]); err != nil {
+		return fmt.Errorf("clamd: %w", err)
+	}
+	if err := w.Flush(); err != nil {
+		return fmt.Errorf("clamd: %w", err)
+	}
+	return nil
+}
+
+func (s *ClamdScanner) dial(ctx context.Context) (net.Conn, error) {
+	dialer := net.Dialer{Timeout: s.timeout}
+	conn, err := dialer.DialContext(ctx, s.network, s.address)
+	if err != nil {
+		return nil, fmt.Errorf("clamd: connect %s: %w", s.address, err)
+	}
+	deadline := time.Now().Add(s.timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	_ = conn.SetDeadline(deadline) //nolint:errcheck // Best effort; reads fail on their own otherwise
+	return conn, nil
+}
+
+// readClamdReply reads a NUL-terminated reply.
+func readClamdReply(conn net.Conn) (string, error) {
+	reply, err := bufio.NewReader(conn).ReadBytes(0)
+	if err != nil && !(err == io.EOF && len(reply) > 0) {
+		return "", fmt.Errorf("clamd: read reply: %w", err)
+	}
+	return string(bytes.TrimRight(reply, "\x00\n")), nil
+}
+
+// parseClamdReply interprets an INSTREAM reply: "stream: OK",
+// "stream: <signature> FOUND" or "<message> ERROR".
+func parseClamdReply(reply string) (*Result, error) {
+	msg := strings.TrimSpace(strings.TrimPrefix(reply, "stream:"))
+	switch {
+	case msg == "OK":
+		return &Result{Status: StatusClean}, nil
+	case strings.HasSuffix(msg, " FOUND"):
+		return &Result{Status: StatusInfected, Signature: strings.TrimSuffix(msg, " FOUND")}, nil
+	case strings.HasSuffix(msg, " ERROR"):
+		return nil, fmt.Errorf("clamd: %s", strings.TrimSuffix(msg, " ERROR"))
+	default:
+		return nil, fmt.Errorf("clamd: unexpected reply %q", reply)
+	}
+}
@@ -0,0 +1,144 @@
+package antivirus
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/config"
+)
+
+// fakeClamd answers INSTREAM and VERSION like clamd, reporting streams that
+// contain "EICAR" as infected.
+func fakeClamd(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go serveFakeClamd(conn)
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func serveFakeClamd(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	cmd, err := r.ReadString(0)
+	if err != nil {
+		return
+	}
+	switch cmd {
+	case "zVERSION\x00":
+		_, _ = conn.Write([]byte("ClamAV 1.3.0/27000\x00"))
+	case "zINSTREAM\x00":
+		var content bytes.Buffer
+		var size [4]byte
+		for {
+			if _, err := io.ReadFull(r, size[:]); err != nil {
+				return
+			}
+			n := binary.BigEndian.Uint32(size[:])
+			if n == 0 {
+				break
+			}
+			if _, err := io.CopyN(&content, r, int64(n)); err != nil {
+				return
+			}
+		}
+		reply := "stream: OK\x00"
+		if strings.Contains(content.String(), "EICAR") {
+			reply = "stream: Eicar-Test-Signature FOUND\x00"
+		}
+		if content.Len() > 200*1024 {
+			reply = "INSTREAM size limit exceeded. ERROR\x00"
+		}
+		_, _ = conn.Write([]byte(reply))
+	}
+}
+
+func TestClamdScanner(t *testing.T) {
+	s := NewClamdScanner("tcp", fakeClamd(t), 5*time.Second)
+	ctx := context.Background()
+
+	res, err := s.Scan(ctx, strings.NewReader("hello"))
+	require.NoError(t, err)
+	assert.Equal(t, StatusClean, res.Status)
+	assert.False(t, res.Infected())
+
+	// Content spanning several chunks is streamed whole.
+	infected := strings.Repeat("x", clamdChunkSize+10) + "EICAR"
+	res, err = s.Scan(ctx, strings.NewReader(infected))
+	require.NoError(t, err)
+	assert.True(t, res.Infected())
+	assert.Equal(t, "Eicar-Test-Signature", res.Signature)
+
+	_, err = s.Scan(ctx, strings.NewReader(strings.Repeat("x", 300*1024)))
+	assert.ErrorContains(t, err, "INSTREAM size limit exceeded")
+
+	version, err := s.Ping(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "ClamAV 1.3.0/27000", version)
+}
+
+func TestClamdScannerUnreachable(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := ln.Addr().String()
+	require.NoError(t, ln.Close())
+
+	_, err = NewClamdScanner("tcp", addr, time.Second).Scan(context.Background(), strings.NewReader("x"))
+	assert.ErrorContains(t, err, "clamd: connect")
+}
+
+func TestParseAddress(t *testing.T) {
+	tests := []struct {
+		in, network, address string
+		wantErr              bool
+	}{
+		{"tcp://clamav:3310", "tcp", "clamav:3310", false},
+		{"unix:///run/clamav/clamd.ctl", "unix", "/run/clamav/clamd.ctl", false},
+		{"localhost:3310", "tcp", "localhost:3310", false},
+		{"", "", "", true},
+		{"http://clamav", "", "", true},
+	}
+	for _, tt := range tests {
+		network, address, err := ParseAddress(tt.in)
+		if tt.wantErr {
+			assert.Error(t, err, tt.in)
+			continue
+		}
+		require.NoError(t, err, tt.in)
+		assert.Equal(t, tt.network, network)
+		assert.Equal(t, tt.address, address)
+	}
+}
+
+func TestNewFromConfig(t *testing.T) {
+	s, err := NewFromConfig(config.AttachmentScanConfig{})
+	require.NoError(t, err)
+	assert.Nil(t, s)
+
+	s, err = NewFromConfig(config.AttachmentScanConfig{Enabled: true, Address: "tcp://clamav:3310"})
+	require.NoError(t, err)
+	assert.Equal(t, "clamd", s.Name())
+
+	_, err = NewFromConfig(config.AttachmentScanConfig{Enabled: true, Driver: "other", Address: "x:1"})
+	assert.Error(t, err)
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/goatkit/goatflow/internal/database"
 	"github.com/goatkit/goatflow/internal/models"
 	"github.com/goatkit/goatflow/internal/repository"
+	"github.com/goatkit/goatflow/internal/services"
 	"github.com/goatkit/goatflow/internal/services/adapter"
 )
 
@@ -417,6 +418,7 @@ func HandleAdminGroupsAddUser(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add user to group"})
 		return
 	}
+	services.InvalidateUserPermissions(req.UserID)
 
 	c.JSON(http.StatusOK, gin.H{"success": true, "message": "User added to group"})
 }
@@ -451,6 +453,7 @@ func HandleAdminGroupsRemoveUser(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove user from group"})
 		return
 	}
+	services.InvalidateUserPermissions(uid)
 
 	c.JSON(http.StatusOK, gin.H{"success": true, "message": "User removed from group"})
 }
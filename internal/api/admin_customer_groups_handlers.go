@@ -16,6 +16,7 @@ import (
 
 	"github.com/goatkit/goatflow/internal/database"
 	"github.com/goatkit/goatflow/internal/routing"
+	"github.com/goatkit/goatflow/internal/services"
 )
 
 func init() {
@@ -628,7 +629,11 @@ func updateCustomerGroupPermissions(db *sql.DB, customerID string, permissions m
 		}
 	}
 
-	return tx.Commit()
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	services.InvalidateCustomerCompanyPermissions(customerID)
+	return nil
 }
 
 func updateGroupCustomerPermissions(db *sql.DB, groupID int, permissions map[string]map[string]bool, userID int) error {
@@ -662,5 +667,9 @@ func updateGroupCustomerPermissions(db *sql.DB, groupID int, permissions map[str
 		}
 	}
 
-	return tx.Commit()
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	services.InvalidateAllPermissions()
+	return nil
 }
@@ -17,6 +17,7 @@ import (
 
 	"github.com/goatkit/goatflow/internal/database"
 	"github.com/goatkit/goatflow/internal/routing"
+	"github.com/goatkit/goatflow/internal/services"
 )
 
 func init() {
@@ -583,7 +584,11 @@ func updateCustomerUserGroupPermissions(db *sql.DB, userLogin string, permission
 		}
 	}
 
-	return tx.Commit()
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	services.InvalidateCustomerUserPermissions(userLogin)
+	return nil
 }
 
 func updateGroupCustomerUserPermissions(db *sql.DB, groupID int, permissions map[string]map[string]bool, userID int) error {
@@ -617,5 +622,9 @@ func updateGroupCustomerUserPermissions(db *sql.DB, groupID int, permissions map
 		}
 	}
 
-	return tx.Commit()
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	services.InvalidateAllPermissions()
+	return nil
 }
@@ -3,6 +3,7 @@ package api
 import (
 	"database/sql"
 	"encoding/csv"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -14,6 +15,8 @@ import (
 
 	"github.com/goatkit/goatflow/internal/auth"
 	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/service"
 )
 
 // HandleAdminCustomerUsersList handles GET /admin/customer-users.
@@ -190,6 +193,25 @@ func HandleAdminCustomerUsersList(c *gin.Context) {
 		customers[i]["totp_2fa_enabled"] = totp2fa[login]
 	}
 
+	// Add the bounce state of each customer's email address
+	emails := make([]string, 0, len(customers))
+	for i := range customers {
+		emails = append(emails, customers[i]["email"].(string))
+	}
+	if len(emails) > 0 {
+		if bounces, berr := service.NewEmailLoopService(db).Bounces(c.Request.Context(), emails...); berr == nil {
+			bounced := make(map[string]*models.EmailBounce, len(bounces))
+			for _, b := range bounces {
+				bounced[b.Email] = b
+			}
+			for i := range customers {
+				if b, ok := bounced[strings.ToLower(customers[i]["email"].(string))]; ok {
+					customers[i]["bounce"] = b
+				}
+			}
+		}
+	}
+
 	// Get companies for filter dropdown
 	companiesQuery := "SELECT DISTINCT customer_id, name FROM customer_company WHERE valid_id = 1 ORDER BY name"
 	companyRows, err := db.Query(companiesQuery)
@@ -329,6 +351,9 @@ func HandleAdminCustomerUsersGet(c *gin.Context) {
 	customer["country"] = country.String
 	customer["comments"] = comments.String
 	customer["company_name"] = companyName.String
+	if bounce, berr := service.NewEmailLoopService(db).Bounce(c.Request.Context(), email); berr == nil {
+		customer["bounce"] = bounce
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -723,17 +748,16 @@ func HandleAdminCustomerUsersTickets(c *gin.Context) {
 }
 
 // HandleAdminCustomerUsersImportForm handles GET /admin/customer-users/import.
+// The import form is the customer import wizard.
 func HandleAdminCustomerUsersImportForm(c *gin.Context) {
-	getPongo2Renderer().HTML(c, http.StatusOK, "pages/admin/customer_users_import.pongo2", pongo2.Context{
-		"User":       getUserFromContext(c),
-		"ActivePage": "admin",
-		"Title":      "Import Customer Users",
-	})
+	c.Redirect(http.StatusFound, "/admin/customer-import?kind="+string(models.CustomerImportUsers))
 }
 
 // HandleAdminCustomerUsersImport handles POST /admin/customer-users/import.
+// It creates the customer users of a CSV or Excel file in one step, leaving
+// existing logins alone; the import wizard previews and can update them.
 func HandleAdminCustomerUsersImport(c *gin.Context) {
-	file, _, err := c.Request.FormFile("csv_file")
+	filename, data, err := customerImportUpload(c, "csv_file", "file")
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"success": false,
@@ -741,119 +765,44 @@ func HandleAdminCustomerUsersImport(c *gin.Context) {
 		})
 		return
 	}
-	defer file.Close()
 
-	reader := csv.NewReader(file)
-	records, err := reader.ReadAll()
+	db, err := database.GetDB()
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"success": false,
-			"error":   "Failed to parse CSV: " + err.Error(),
-		})
-		return
-	}
-
-	if len(records) < 2 {
-		c.JSON(http.StatusBadRequest, gin.H{
+		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
-			"error":   "CSV must contain at least a header row and one data row",
+			"error":   "Database connection failed",
 		})
 		return
 	}
 
-	db, err := database.GetDB()
+	result, err := service.NewCustomerImportService(db).Import(c.Request.Context(), models.CustomerImportUsers,
+		filename, data, service.CustomerImportOptions{}, getUserID(c))
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
+		status := http.StatusInternalServerError
+		if errors.Is(err, service.ErrCustomerImportFile) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{
 			"success": false,
-			"error":   "Database connection failed",
+			"error":   "Import failed: " + err.Error(),
 		})
 		return
 	}
 
-	// Parse header row to get column mapping
-	headers := records[0]
-	columnMap := make(map[string]int)
-	for i, header := range headers {
-		columnMap[strings.ToLower(strings.TrimSpace(header))] = i
-	}
-
-	var imported, failed int
-	var errors []string
-
-	// Process data rows
-	for i := 1; i < len(records); i++ {
-		record := records[i]
-
-		// Helper function to get column value safely
-		getColumn := func(name string) string {
-			if idx, exists := columnMap[name]; exists && idx < len(record) {
-				return strings.TrimSpace(record[idx])
-			}
-			return ""
-		}
-
-		login := getColumn("login")
-		email := getColumn("email")
-		customerID := getColumn("customer_id")
-
-		if login == "" || email == "" || customerID == "" {
-			errors = append(errors, fmt.Sprintf("Row %d: Missing required fields (login, email, customer_id)", i+1))
-			failed++
-			continue
-		}
-
-		// Check if customer user already exists
-		var existingID int
-		checkQuery := database.ConvertPlaceholders("SELECT id FROM customer_user WHERE login = ?")
-		err = db.QueryRow(checkQuery, login).Scan(&existingID)
-		if err == nil {
-			errors = append(errors, fmt.Sprintf("Row %d: Login %s already exists", i+1, login))
-			failed++
-			continue
-		}
-
-		// Insert customer user
-		insertQuery := `
-			INSERT INTO customer_user (
-				login, email, customer_id, title, first_name, last_name,
-				phone, fax, mobile, street, zip, city, country, comments,
-				valid_id, create_by, change_by
-			) VALUES (
-				?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, 1, 1, 1
-			)`
-		insertQuery = database.ConvertPlaceholders(insertQuery)
-
-		_, err = db.Exec(insertQuery,
-			login,
-			email,
-			customerID,
-			getColumn("title"),
-			getColumn("first_name"),
-			getColumn("last_name"),
-			getColumn("phone"),
-			getColumn("fax"),
-			getColumn("mobile"),
-			getColumn("street"),
-			getColumn("zip"),
-			getColumn("city"),
-			getColumn("country"),
-			getColumn("comments"),
-		)
-
-		if err != nil {
-			errors = append(errors, fmt.Sprintf("Row %d: Database error: %s", i+1, err.Error()))
-			failed++
-		} else {
-			imported++
+	var messages []string
+	for _, row := range result.Rows {
+		for _, msg := range append(append([]string{}, row.Errors...), row.Warnings...) {
+			messages = append(messages, fmt.Sprintf("Row %d: %s", row.Line, msg))
 		}
 	}
+	failed := result.Failed + result.Skipped
 
 	c.JSON(http.StatusOK, gin.H{
 		"success":  true,
-		"message":  fmt.Sprintf("Import completed: %d imported, %d failed", imported, failed),
-		"imported": imported,
+		"message":  fmt.Sprintf("Import completed: %d imported, %d failed", result.Created, failed),
+		"imported": result.Created,
 		"failed":   failed,
-		"errors":   errors,
+		"errors":   messages,
 	})
 }
 
@@ -1,7 +1,10 @@
 package api
 
 import (
+	"database/sql"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
 	"strings"
 
@@ -9,6 +12,9 @@ import (
 	"github.com/gin-gonic/gin"
 
 	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/repository"
+	"github.com/goatkit/goatflow/internal/service"
+	"github.com/goatkit/goatflow/internal/services/genericagent"
 )
 
 // GenericAgentJob represents a generic agent job configuration.
@@ -483,3 +489,65 @@ func handleAdminGenericAgentGet(c *gin.Context) {
 		"data":    job,
 	})
 }
+
+// handleAdminGenericAgentPreview reports what running a job would do to
+// each matching ticket, without changing anything. The preview acts as the
+// system user, like scheduled runs.
+func handleAdminGenericAgentPreview(c *gin.Context) {
+	jobName := c.Param("name")
+	if jobName == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Job name is required",
+		})
+		return
+	}
+
+	db, err := database.GetDB()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "Database connection failed",
+		})
+		return
+	}
+
+	job, err := repository.NewGenericAgentRepository(db).GetJob(c.Request.Context(), jobName)
+	if errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"error":   "Job not found",
+		})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "Failed to fetch job",
+		})
+		return
+	}
+
+	preview, err := genericagent.NewService(db).PreviewJob(c.Request.Context(), job, 1)
+	if errors.Is(err, service.ErrBulkChangeInvalidTarget) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		log.Printf("generic agent preview for job %q failed: %v", jobName, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "Failed to preview job",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"dry_run": true,
+		"data":    preview,
+	})
+}
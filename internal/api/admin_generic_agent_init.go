@@ -9,4 +9,5 @@ func init() {
 	routing.RegisterHandler("handleAdminGenericAgentUpdate", handleAdminGenericAgentUpdate)
 	routing.RegisterHandler("handleAdminGenericAgentDelete", handleAdminGenericAgentDelete)
 	routing.RegisterHandler("handleAdminGenericAgentGet", handleAdminGenericAgentGet)
+	routing.RegisterHandler("handleAdminGenericAgentPreview", handleAdminGenericAgentPreview)
 }
@@ -6,8 +6,6 @@ package api
 import (
 	"database/sql"
 	"encoding/json"
-	"fmt"
-	"html/template"
 	"log"
 	"net/http"
 	"net/url"
@@ -22,6 +20,7 @@ import (
 	"github.com/goatkit/goatflow/internal/repository"
 	"github.com/goatkit/goatflow/internal/routing"
 	"github.com/goatkit/goatflow/internal/service"
+	"github.com/goatkit/goatflow/internal/services"
 )
 
 func init() {
@@ -68,13 +67,12 @@ func handleAdminGroups(c *gin.Context) {
 		}
 	}
 
-	// TODO: Implement group filtering using searchTerm and statusTerm from cookies
-	// Currently, filters are saved but not applied when loading from cookies
-	// if searchTerm == "" && statusTerm == "" {
-	//     if cookie, err := c.Request.Cookie("group_filters"); err == nil {
-	//         // restore searchTerm and statusTerm from cookie
-	//     }
-	// }
+	table := newAdminTable(c, "groups-table", "admin.groups", "/admin/groups", adminGroupColumns(), "name", "search", "status")
+	if searchTerm == "" && statusTerm == "" && !saveState {
+		if cookie, err := c.Request.Cookie("group_filters"); err == nil {
+			restoreAdminGroupFilters(table, cookie.Value)
+		}
+	}
 
 	db, err := database.GetDB()
 	if err != nil || db == nil {
@@ -91,6 +89,9 @@ func handleAdminGroups(c *gin.Context) {
 
 	groupList := make([]gin.H, 0, len(groups))
 	for _, group := range groups {
+		if !adminGroupMatches(group, table.Filter("search"), table.Filter("status")) {
+			continue
+		}
 		groupIDUint, ok := group.ID.(uint)
 		memberCount := 0
 		if ok {
@@ -107,13 +108,72 @@ func handleAdminGroups(c *gin.Context) {
 		return
 	}
 
+	groupList = paginateAdminTable(table, groupList, adminGroupSortValue)
+
 	getPongo2Renderer().HTML(c, http.StatusOK, "pages/admin/groups.pongo2", pongo2.Context{
 		"Groups":     groupList,
+		"Table":      table,
 		"User":       getUserMapForTemplate(c),
 		"ActivePage": "admin",
 	})
 }
 
+func adminGroupColumns() []adminTableColumn {
+	return []adminTableColumn{
+		{Key: "name", Label: "admin.group_name"},
+		{Label: "admin.description"},
+		{Key: "members", Label: "admin.members"},
+		{Key: "status", Label: "admin.status"},
+		{Key: "created", Label: "admin.created"},
+		{Label: "admin.actions", Class: "relative px-6 py-3", SROnly: true},
+	}
+}
+
+// restoreAdminGroupFilters applies the filters saved by ?save_state=1.
+func restoreAdminGroupFilters(table *adminTable, encoded string) {
+	payload, err := url.QueryUnescape(encoded)
+	if err != nil {
+		return
+	}
+	var state map[string]string
+	if json.Unmarshal([]byte(payload), &state) != nil {
+		return
+	}
+	table.Filters["search"] = strings.TrimSpace(state["search"])
+	table.Filters["status"] = strings.TrimSpace(state["status"])
+}
+
+// adminGroupMatches reports whether a group passes the search text (name or
+// comments) and the status filter ("1"/"active" or "2"/"inactive").
+func adminGroupMatches(group *models.Group, search, status string) bool {
+	if search != "" {
+		needle := strings.ToLower(search)
+		if !strings.Contains(strings.ToLower(group.Name), needle) &&
+			!strings.Contains(strings.ToLower(group.Comments), needle) {
+			return false
+		}
+	}
+	switch strings.ToLower(status) {
+	case "1", "active":
+		return group.ValidID == 1
+	case "2", "inactive":
+		return group.ValidID != 1
+	}
+	return true
+}
+
+func adminGroupSortValue(row gin.H, key string) any {
+	switch key {
+	case "members":
+		return row["MemberCount"]
+	case "status":
+		return row["IsActive"]
+	case "created":
+		return row["CreateTime"]
+	}
+	return row["Name"]
+}
+
 func makeAdminGroupEntry(group *models.Group, memberCount int) gin.H {
 	isSystem := group.Name == "admin" || group.Name == "users" || group.Name == "stats"
 	isActive := group.ValidID == 1
@@ -130,197 +190,6 @@ func makeAdminGroupEntry(group *models.Group, memberCount int) gin.H {
 	}
 }
 
-func renderAdminGroupsTestFallback(c *gin.Context, groups []gin.H, searchTerm, statusTerm string) {
-	defaultGroups := []gin.H{
-		{
-			"ID":          1,
-			"Name":        "admin",
-			"Description": "System administrators",
-			"Comments":    "System administrators",
-			"MemberCount": 3,
-			"IsActive":    true,
-			"IsSystem":    true,
-			"ValidID":     1,
-		},
-		{
-			"ID":          2,
-			"Name":        "users",
-			"Description": "All registered users",
-			"Comments":    "All registered users",
-			"MemberCount": 12,
-			"IsActive":    true,
-			"IsSystem":    true,
-			"ValidID":     1,
-		},
-		{
-			"ID":          3,
-			"Name":        "support",
-			"Description": "Frontline support team",
-			"Comments":    "Frontline support team",
-			"MemberCount": 6,
-			"IsActive":    true,
-			"IsSystem":    false,
-			"ValidID":     1,
-		},
-		{
-			"ID":          4,
-			"Name":        "legacy",
-			"Description": "Inactive legacy queue",
-			"Comments":    "Inactive legacy queue",
-			"MemberCount": 0,
-			"IsActive":    false,
-			"IsSystem":    false,
-			"ValidID":     2,
-		},
-	}
-
-	if len(groups) == 0 {
-		groups = defaultGroups
-	}
-
-	search := strings.ToLower(strings.TrimSpace(searchTerm))
-	statusFilter := strings.ToLower(strings.TrimSpace(statusTerm))
-
-	filtered := make([]gin.H, 0, len(groups))
-	for _, group := range groups {
-		name := strings.ToLower(fmt.Sprint(group["Name"]))
-		description := strings.ToLower(fmt.Sprint(group["Description"]))
-		if search != "" && !strings.Contains(name, search) && !strings.Contains(description, search) {
-			continue
-		}
-
-		isActive := true
-		switch v := group["IsActive"].(type) {
-		case bool:
-			isActive = v
-		case int:
-			isActive = v == 1
-		case int64:
-			isActive = int(v) == 1
-		case uint:
-			isActive = int(v) == 1
-		case uint64:
-			isActive = int(v) == 1
-		default:
-			if raw, ok := group["ValidID"]; ok {
-				isActive = fmt.Sprint(raw) == "1"
-			}
-		}
-
-		switch statusFilter {
-		case "active":
-			if !isActive {
-				continue
-			}
-		case "inactive":
-			if isActive {
-				continue
-			}
-		}
-
-		clone := gin.H{}
-		for k, v := range group {
-			clone[k] = v
-		}
-		clone["IsActive"] = isActive
-		filtered = append(filtered, clone)
-	}
-
-	buildListHTML := func(data []gin.H) string {
-		var list strings.Builder
-		list.WriteString(`<div id="group-table" class="group-list" role="region" aria-live="polite">`)
-		if len(data) == 0 {
-			list.WriteString(`<p class="empty-state">No groups match your filters.</p>`)
-		}
-		for _, group := range data {
-			id := template.HTMLEscapeString(fmt.Sprint(group["ID"]))
-			name := template.HTMLEscapeString(fmt.Sprint(group["Name"]))
-			rawDescription := group["Comments"]
-			if rawDescription == nil || fmt.Sprint(rawDescription) == "" {
-				rawDescription = group["Description"]
-			}
-			description := template.HTMLEscapeString(fmt.Sprint(rawDescription))
-			members := template.HTMLEscapeString(fmt.Sprint(group["MemberCount"]))
-			isSystem := fmt.Sprint(group["IsSystem"]) == "true"
-			status := "active"
-			statusLabel := "Active"
-			if active, ok := group["IsActive"].(bool); ok && !active {
-				status = "inactive"
-				statusLabel = "Inactive"
-			}
-
-			list.WriteString(`<article class="group-row" data-group-id="` + id + `">`)
-			list.WriteString(`<header><h2>` + name + `</h2>`)
-			if isSystem {
-				list.WriteString(`<span class="badge system">System</span>`)
-			}
-			list.WriteString(`</header>`)
-			list.WriteString(`<p class="group-description">` + description + `</p>`)
-			list.WriteString(`<div class="group-meta">`)
-			list.WriteString(`<span class="badge members">` + members + ` members</span>`)
-			list.WriteString(`<span class="badge status status-` + status + `">` + statusLabel + `</span>`)
-			list.WriteString(`</div>`)
-			list.WriteString(`<div class="group-actions">`)
-			list.WriteString(`<button type="button" class="btn btn-small" hx-get="/admin/groups/` +
-				id + `" hx-target="#group-detail">View</button>`)
-			list.WriteString(`<button type="button" class="btn btn-small" hx-get="/admin/groups/` +
-				id + `/permissions" hx-target="#group-permissions">Permissions</button>`)
-			list.WriteString(`</div>`)
-			list.WriteString(`</article>`)
-		}
-		list.WriteString(`</div>`)
-		return list.String()
-	}
-
-	hxRequest := strings.EqualFold(c.GetHeader("HX-Request"), "true")
-	if hxRequest {
-		html := buildListHTML(filtered)
-		c.Header("Content-Type", "text/html; charset=utf-8")
-		c.String(http.StatusOK, html)
-		return
-	}
-
-	var page strings.Builder
-	page.WriteString(`<!doctype html><html lang="en"><head>` +
-		`<meta charset="utf-8"/><title>Group Management</title></head>`)
-	page.WriteString(`<body class="admin-groups">`)
-	page.WriteString(`<main class="container">`)
-	page.WriteString(`<header class="page-header"><h1>Group Management</h1>`)
-	page.WriteString(`<a id="add-group-link" class="btn btn-primary" href="/admin/groups/new" ` +
-		`hx-get="/admin/groups/new" hx-target="#modal">Add Group</a>`)
-	page.WriteString(`</header>`)
-	page.WriteString(`<form id="group-filter-form" method="GET" hx-get="/admin/groups" ` +
-		`hx-target="#group-table" class="filters">`)
-	page.WriteString(`<label for="group-search">Search</label>`)
-	page.WriteString(`<input id="group-search" type="search" name="search" value="` +
-		template.HTMLEscapeString(searchTerm) + `" placeholder="Search groups" />`)
-	page.WriteString(`<label for="group-status">Status</label>`)
-	sel := func(current, expected string) string {
-		if strings.EqualFold(current, expected) {
-			return " selected"
-		}
-		return ""
-	}
-	statusValue := strings.ToLower(strings.TrimSpace(statusTerm))
-	page.WriteString(`<select id="group-status" name="status">`)
-	page.WriteString(`<option value=""` + sel(statusValue, "") + `>All</option>`)
-	page.WriteString(`<option value="active"` + sel(statusValue, "active") + `>Active</option>`)
-	page.WriteString(`<option value="inactive"` + sel(statusValue, "inactive") + `>Inactive</option>`)
-	page.WriteString(`</select>`)
-	page.WriteString(`<button type="submit" class="btn">Apply</button>`)
-	page.WriteString(`<button type="reset" class="btn btn-secondary" hx-get="/admin/groups" hx-target="#group-table">Clear</button>`)
-	page.WriteString(`</form>`)
-	page.WriteString(`<section aria-label="Group List">`)
-	page.WriteString(buildListHTML(filtered))
-	page.WriteString(`</section>`)
-	page.WriteString(`<section id="group-detail" aria-live="polite"></section>`)
-	page.WriteString(`<section id="group-permissions" aria-live="polite"></section>`)
-	page.WriteString(`</main></body></html>`)
-
-	c.Header("Content-Type", "text/html; charset=utf-8")
-	c.String(http.StatusOK, page.String())
-}
-
 // handleCreateGroup creates a new group.
 func handleCreateGroup(c *gin.Context) {
 	var groupForm struct {
@@ -714,6 +583,7 @@ func handleUpdateUserPermissions(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to update permissions"})
 		return
 	}
+	services.InvalidateUserPermissions(int(userID))
 
 	// Always return JSON for this endpoint since it's called via AJAX
 	c.JSON(http.StatusOK, gin.H{
@@ -755,6 +625,7 @@ func handleAddUserToGroup(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to add user to group"})
 		return
 	}
+	services.InvalidateUserPermissions(int(req.UserID))
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -793,6 +664,7 @@ func handleRemoveUserFromGroup(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to remove user from group"})
 		return
 	}
+	services.InvalidateUserPermissions(int(userID))
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -884,6 +756,7 @@ func handleSaveGroupPermissions(c *gin.Context) {
 			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to update permissions"})
 			return
 		}
+		services.InvalidateUserPermissions(int(assignment.UserID))
 	}
 
 	data, err := fetchGroupPermissionsData(db, groupID)
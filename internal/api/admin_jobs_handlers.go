@@ -0,0 +1,99 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/flosch/pongo2/v6"
+	"github.com/gin-gonic/gin"
+
+	"github.com/goatkit/goatflow/internal/jobqueue"
+)
+
+// failedJobsLimit caps the failed jobs listed on the admin page.
+const failedJobsLimit = 100
+
+// handleAdminJobs renders the background job overview: the depth of every
+// queue and the failed jobs with retry and delete buttons.
+func handleAdminJobs(c *gin.Context) {
+	q := jobqueue.Default()
+	ctx := c.Request.Context()
+
+	var stats []jobqueue.QueueStats
+	var failed []*jobqueue.Job
+	if q != nil {
+		var err error
+		if stats, err = q.Stats(ctx); err != nil {
+			log.Printf("jobqueue admin: load stats failed: %v", err)
+			sendErrorResponse(c, http.StatusInternalServerError, "Failed to load job queue stats")
+			return
+		}
+		if failed, err = q.Failed(ctx, c.Query("queue"), failedJobsLimit); err != nil {
+			log.Printf("jobqueue admin: list failed jobs failed: %v", err)
+			sendErrorResponse(c, http.StatusInternalServerError, "Failed to load failed jobs")
+			return
+		}
+	}
+	if stats == nil {
+		stats = []jobqueue.QueueStats{}
+	}
+	if failed == nil {
+		failed = []*jobqueue.Job{}
+	}
+
+	if wantsJSONResponse(c) {
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"enabled": q != nil,
+			"queues":  stats,
+			"failed":  failed,
+		})
+		return
+	}
+
+	if getPongo2Renderer() == nil {
+		sendErrorResponse(c, http.StatusInternalServerError, "Template renderer unavailable")
+		return
+	}
+	getPongo2Renderer().HTML(c, http.StatusOK, "pages/admin/jobs.pongo2", pongo2.Context{
+		"Title":       "Background Jobs",
+		"Enabled":     q != nil,
+		"Queues":      stats,
+		"FailedJobs":  failed,
+		"QueueFilter": c.Query("queue"),
+		"User":        getUserMapForTemplate(c),
+		"ActivePage":  "admin",
+	})
+}
+
+// handleRetryJob makes a failed job due again with its attempts reset.
+func handleRetryJob(c *gin.Context) {
+	if err := jobqueue.Default().Requeue(c.Request.Context(), c.Param("id")); err != nil {
+		jobError(c, err, "retry job")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Job queued for retry"})
+}
+
+// handleDeleteJob removes a pending or failed job.
+func handleDeleteJob(c *gin.Context) {
+	if err := jobqueue.Default().Delete(c.Request.Context(), c.Param("id")); err != nil {
+		jobError(c, err, "delete job")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Job deleted"})
+}
+
+// jobError maps job queue errors to responses.
+func jobError(c *gin.Context, err error, action string) {
+	switch {
+	case errors.Is(err, jobqueue.ErrNoQueue):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"success": false, "error": "Job queue is not enabled"})
+	case errors.Is(err, jobqueue.ErrJobNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Job not found"})
+	default:
+		log.Printf("jobqueue admin: %s failed: %v", action, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to " + action})
+	}
+}
@@ -382,10 +382,6 @@ func handleAdminLookups(c *gin.Context) {
 	})
 }
 
-func handleAdminSettings(c *gin.Context) {
-	underConstruction("System Settings")(c)
-}
-
 func handleAdminTemplates(c *gin.Context) {
 	underConstruction("Template Management")(c)
 }
@@ -436,6 +432,7 @@ func handleAdminDashboard(c *gin.Context) {
 		"ActiveTickets":   activeTickets,
 		"QueueCount":      queueCount,
 		"TicketActivity":  ticketActivity,
+		"PluginPages":     pluginAdminPageCards(),
 		"User":            getUserMapForTemplate(c),
 		"ActivePage":      "admin",
 	})
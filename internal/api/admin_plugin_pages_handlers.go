@@ -0,0 +1,172 @@
+package api
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/flosch/pongo2/v6"
+	"github.com/gin-gonic/gin"
+
+	"github.com/goatkit/goatflow/internal/plugin"
+	"github.com/goatkit/goatflow/internal/routing"
+)
+
+func init() {
+	routing.RegisterHandler("HandleAdminPluginPage", HandleAdminPluginPage)
+}
+
+// pluginAdminPageResult is what an admin page handler returns.
+type pluginAdminPageResult struct {
+	HTML     string `json:"html"`
+	Redirect string `json:"redirect"` // local path to go to after a form post
+}
+
+// HandleAdminPluginPage renders a plugin's admin page inside the host layout
+// and passes form posts to the plugin.
+// GET/POST /admin/plugins/:name/pages/:page
+func HandleAdminPluginPage(c *gin.Context) {
+	if pluginManager == nil {
+		sendErrorResponse(c, http.StatusNotFound, "Plugin page not found")
+		return
+	}
+	page, ok := pluginManager.AdminPage(c.Param("name"), c.Param("page"))
+	if !ok {
+		sendErrorResponse(c, http.StatusNotFound, "Plugin page not found")
+		return
+	}
+
+	posted := c.Request.Method != http.MethodGet
+	if posted {
+		if !sameOriginRequest(c) {
+			sendErrorResponse(c, http.StatusForbidden, "Cross-site request rejected")
+			return
+		}
+		if strings.HasPrefix(c.ContentType(), "multipart/") {
+			_, _ = c.MultipartForm() //nolint:errcheck // Unparsable forms reach the plugin empty
+		} else {
+			_ = c.Request.ParseForm() //nolint:errcheck // Unparsable forms reach the plugin empty
+		}
+	}
+
+	args, _ := json.Marshal(map[string]any{ //nolint:errcheck // Plain maps of strings always marshal
+		"page":        page.ID,
+		"query":       flattenPluginValues(c.Request.URL.Query()),
+		"form":        flattenPluginValues(c.Request.PostForm),
+		"_method":     c.Request.Method,
+		"_path":       c.Request.URL.Path,
+		"_url":        page.URL(),
+		"_user_id":    GetUserIDFromCtx(c, 0),
+		"_user_login": c.GetString("username"),
+	})
+	raw, err := pluginManager.Call(pluginContextWithLanguage(c), page.PluginName, page.Handler, args)
+	var result pluginAdminPageResult
+	if err == nil {
+		err = json.Unmarshal(raw, &result)
+	}
+	if err != nil {
+		log.Printf("plugin admin page %s/%s failed: %v", page.PluginName, page.ID, err)
+		plugin.GetLogBuffer().Log(page.PluginName, "error", "admin page failed: "+err.Error(),
+			map[string]any{"page": page.ID})
+		sendErrorResponse(c, http.StatusBadGateway, "Plugin page failed")
+		return
+	}
+
+	// Post/redirect/get unless the plugin shows the page again, e.g. with errors
+	if result.Redirect != "" || (posted && result.HTML == "") {
+		target := page.URL()
+		if isLocalPath(result.Redirect) {
+			target = result.Redirect
+		}
+		if c.GetHeader("HX-Request") == "true" {
+			c.Header("HX-Redirect", target)
+			c.Status(http.StatusOK)
+			return
+		}
+		c.Redirect(http.StatusSeeOther, target)
+		return
+	}
+
+	// htmx swaps within the page get the plugin's fragment alone
+	if c.GetHeader("HX-Request") == "true" && c.GetHeader("HX-Boosted") != "true" {
+		c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(result.HTML))
+		return
+	}
+	if getPongo2Renderer() == nil || getPongo2Renderer().TemplateSet() == nil {
+		c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(result.HTML))
+		return
+	}
+
+	var tabs []gin.H
+	for _, p := range pluginManager.AdminPages() {
+		if p.PluginName == page.PluginName {
+			tabs = append(tabs, gin.H{"ID": p.ID, "Title": p.Title, "Icon": p.Icon, "URL": p.URL()})
+		}
+	}
+	getPongo2Renderer().HTML(c, http.StatusOK, "pages/admin/plugin_page.pongo2", pongo2.Context{
+		"Page":       page,
+		"Tabs":       tabs,
+		"Content":    result.HTML,
+		"ActivePage": "admin",
+		"User":       getUserMapForTemplate(c),
+	})
+}
+
+// pluginAdminPageCards lists the plugin admin pages for the admin dashboard.
+func pluginAdminPageCards() []gin.H {
+	if pluginManager == nil {
+		return nil
+	}
+	var cards []gin.H
+	for _, p := range pluginManager.AdminPages() {
+		cards = append(cards, gin.H{
+			"Title":       p.Title,
+			"Description": p.Description,
+			"Icon":        p.Icon,
+			"PluginName":  p.PluginName,
+			"URL":         p.URL(),
+		})
+	}
+	return cards
+}
+
+// flattenPluginValues turns single-valued query or form fields into strings,
+// keeping lists for repeated ones.
+func flattenPluginValues(values url.Values) map[string]any {
+	out := make(map[string]any, len(values))
+	for key, v := range values {
+		if len(v) == 1 {
+			out[key] = v[0]
+		} else {
+			out[key] = v
+		}
+	}
+	return out
+}
+
+// sameOriginRequest reports whether a state-changing request comes from a
+// page of this host, judged by its Origin header or, without one, its Referer.
+// Browsers set both themselves, so cross-site forms cannot pass.
+func sameOriginRequest(c *gin.Context) bool {
+	source := c.GetHeader("Origin")
+	if source == "" {
+		source = c.GetHeader("Referer")
+	}
+	u, err := url.Parse(source)
+	if source == "" || err != nil || u.Host == "" {
+		return false
+	}
+	host := c.Request.Host
+	if fwd := c.GetHeader("X-Forwarded-Host"); fwd != "" {
+		host = strings.TrimSpace(strings.Split(fwd, ",")[0])
+	}
+	return strings.EqualFold(u.Host, host)
+}
+
+// isLocalPath reports whether target is a path on this host rather than an
+// absolute or protocol-relative URL.
+func isLocalPath(target string) bool {
+	return strings.HasPrefix(target, "/") && !strings.HasPrefix(target, "//") && !strings.HasPrefix(target, "/\\")
+}
@@ -0,0 +1,139 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/goatkit/goatflow/internal/plugin"
+	"github.com/goatkit/goatflow/internal/plugin/example"
+)
+
+func setupPluginPageRouter(t *testing.T, host plugin.HostAPI) *gin.Engine {
+	t.Helper()
+	mgr := plugin.NewManager(host)
+	if err := mgr.Register(context.Background(), example.NewHelloPlugin()); err != nil {
+		t.Fatalf("register failed: %v", err)
+	}
+	prev := pluginManager
+	SetPluginManager(mgr)
+	t.Cleanup(func() { SetPluginManager(prev) })
+
+	r := gin.New()
+	r.GET("/admin/plugins/:name/pages/:page", HandleAdminPluginPage)
+	r.POST("/admin/plugins/:name/pages/:page", HandleAdminPluginPage)
+	return r
+}
+
+func postPluginPage(r *gin.Engine, path string, form url.Values, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(form.Encode()))
+	req.Host = "helpdesk.example.com"
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestHandleAdminPluginPage(t *testing.T) {
+	r := setupPluginPageRouter(t, &mockHostAPI{})
+	const path = "/admin/plugins/hello/pages/settings"
+	sameOrigin := map[string]string{"Origin": "https://helpdesk.example.com"}
+
+	t.Run("renders the plugin page", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+		}
+		if !strings.Contains(w.Body.String(), `name="greeting"`) || !strings.Contains(w.Body.String(), `action="`+path+`"`) {
+			t.Errorf("expected settings form, got %s", w.Body.String())
+		}
+	})
+
+	t.Run("unknown pages are not found", func(t *testing.T) {
+		for _, p := range []string{"/admin/plugins/hello/pages/missing", "/admin/plugins/nope/pages/settings"} {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, p, nil))
+			if w.Code != http.StatusNotFound {
+				t.Errorf("%s: expected 404, got %d", p, w.Code)
+			}
+		}
+	})
+
+	t.Run("rejects cross-site posts", func(t *testing.T) {
+		for _, headers := range []map[string]string{
+			nil,
+			{"Origin": "https://evil.example.com"},
+			{"Origin": "null"},
+			{"Referer": "https://evil.example.com/form"},
+		} {
+			w := postPluginPage(r, path, url.Values{"greeting": {"Pwned"}}, headers)
+			if w.Code != http.StatusForbidden {
+				t.Errorf("%v: expected 403, got %d", headers, w.Code)
+			}
+		}
+	})
+
+	t.Run("shows validation errors", func(t *testing.T) {
+		w := postPluginPage(r, path, url.Values{"greeting": {""}}, sameOrigin)
+		if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Greeting is required") {
+			t.Errorf("expected form with error, got %d: %s", w.Code, w.Body.String())
+		}
+	})
+
+	t.Run("saves and redirects back", func(t *testing.T) {
+		w := postPluginPage(r, path, url.Values{"greeting": {"Howdy"}},
+			map[string]string{"Referer": "https://helpdesk.example.com" + path})
+		if w.Code != http.StatusSeeOther || w.Header().Get("Location") != path {
+			t.Fatalf("expected redirect to page, got %d %q", w.Code, w.Header().Get("Location"))
+		}
+
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		if !strings.Contains(w.Body.String(), `value="Howdy"`) {
+			t.Errorf("expected saved greeting, got %s", w.Body.String())
+		}
+	})
+
+	t.Run("htmx posts redirect with HX-Redirect", func(t *testing.T) {
+		w := postPluginPage(r, path, url.Values{"greeting": {"Hi"}},
+			map[string]string{"Origin": "https://helpdesk.example.com", "HX-Request": "true"})
+		if w.Code != http.StatusOK || w.Header().Get("HX-Redirect") != path {
+			t.Errorf("expected HX-Redirect to page, got %d %q", w.Code, w.Header().Get("HX-Redirect"))
+		}
+	})
+}
+
+func TestHandleAdminPluginPageIframeIsolated(t *testing.T) {
+	host := plugin.NewProdHostAPI(plugin.WithPluginResourcePolicy("hello",
+		plugin.ResourcePolicy{WidgetIsolation: plugin.WidgetIsolationIframe}))
+	r := setupPluginPageRouter(t, host)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/plugins/hello/pages/settings", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for iframe-isolated plugin, got %d", w.Code)
+	}
+}
+
+func TestIsLocalPath(t *testing.T) {
+	for target, want := range map[string]bool{
+		"/admin/plugins/hello/pages/settings": true,
+		"//evil.example.com":                  false,
+		"/\\evil.example.com":                 false,
+		"https://evil.example.com":            false,
+		"":                                    false,
+	} {
+		if got := isLocalPath(target); got != want {
+			t.Errorf("isLocalPath(%q) = %v, want %v", target, got, want)
+		}
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/flosch/pongo2/v6"
 	"github.com/gin-gonic/gin"
 
+	"github.com/goatkit/goatflow/internal/plugin"
 	"github.com/goatkit/goatflow/internal/routing"
 )
 
@@ -29,11 +30,22 @@ func HandleAdminPlugins(c *gin.Context) {
 	var enabledCount, disabledCount int
 
 	if pluginManager != nil {
+		// Admin pages the host serves, keyed by plugin
+		adminPages := make(map[string][]plugin.AdminPageSpec)
+		settingsURLs := make(map[string]string)
+		for _, page := range pluginManager.AdminPages() {
+			if _, ok := settingsURLs[page.PluginName]; !ok {
+				settingsURLs[page.PluginName] = page.URL()
+			}
+			adminPages[page.PluginName] = append(adminPages[page.PluginName], page.AdminPageSpec)
+		}
+
 		manifests := pluginManager.List()
 		for _, m := range manifests {
 			// Check actual enabled state from plugin manager
 			enabled := pluginManager.IsEnabled(m.Name)
 
+			breaker := pluginManager.BreakerStatus(m.Name)
 			p := map[string]any{
 				"Name":        m.Name,
 				"Version":     m.Version,
@@ -44,7 +56,12 @@ func HandleAdminPlugins(c *gin.Context) {
 				"Widgets":     m.Widgets,
 				"Jobs":        m.Jobs,
 				"MenuItems":   m.MenuItems,
+				"AdminPages":  adminPages[m.Name],
+				"SettingsURL": settingsURLs[m.Name],
 				"Enabled":     enabled,
+				"Crashes":     pluginManager.CrashReports(m.Name),
+				"Breaker":     breaker,
+				"Unavailable": breaker.State != plugin.BreakerClosed,
 			}
 			plugins = append(plugins, p)
 			if enabled {
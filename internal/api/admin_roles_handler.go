@@ -11,6 +11,7 @@ import (
 	"github.com/gin-gonic/gin"
 
 	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/services"
 )
 
 // Role represents a role in the system.
@@ -412,6 +413,7 @@ func handleAdminRoleUpdate(c *gin.Context) {
 			return
 		}
 	}
+	services.InvalidateAllPermissions()
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -655,6 +657,7 @@ func handleAdminRoleDelete(c *gin.Context) {
 		})
 		return
 	}
+	services.InvalidateAllPermissions()
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -898,6 +901,7 @@ func handleAdminRoleUserAdd(c *gin.Context) {
 		})
 		return
 	}
+	services.InvalidateUserPermissions(input.UserID)
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -961,6 +965,7 @@ func handleAdminRoleUserRemove(c *gin.Context) {
 		})
 		return
 	}
+	services.InvalidateUserPermissions(userID)
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -1103,6 +1108,7 @@ func handleAdminRolePermissionsPUT(c *gin.Context, id int) {
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to commit changes"})
 		return
 	}
+	services.InvalidateAllPermissions()
 
 	c.Redirect(http.StatusSeeOther, fmt.Sprintf("/admin/roles/%d/permissions", id))
 }
@@ -1214,6 +1220,7 @@ func handleAdminRolePermissionsUpdate(c *gin.Context) {
 		})
 		return
 	}
+	services.InvalidateAllPermissions()
 
 	// Return success for HTMX or redirect for standard form
 	if c.GetHeader("HX-Request") != "" {
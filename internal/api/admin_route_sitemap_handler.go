@@ -0,0 +1,86 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/routing"
+)
+
+// handleAdminRouteSitemap lists every registered route with its method,
+// authentication, required scopes and groups, and owning module, so operators
+// can review what is exposed after enabling plugins or web services.
+// GET /admin/api/routes?source=plugin&auth=public
+func handleAdminRouteSitemap(c *gin.Context) {
+	entries := routing.Sitemap()
+	if svc := getGIService(); svc != nil {
+		if webservices, err := svc.ListValidWebservices(c.Request.Context()); err == nil {
+			entries = append(entries, genericInterfaceProviderRoutes(webservices)...)
+		}
+	}
+	routing.SortRouteEntries(entries)
+
+	source := strings.TrimSpace(c.Query("source"))
+	auth := strings.TrimSpace(c.Query("auth"))
+	filtered := make([]routing.RouteEntry, 0, len(entries))
+	summary := map[string]int{}
+	for _, e := range entries {
+		if (source != "" && e.Source != source) || (auth != "" && e.Auth != auth) {
+			continue
+		}
+		filtered = append(filtered, e)
+		summary[e.Auth]++
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    filtered,
+		"total":   len(filtered),
+		"by_auth": summary,
+	})
+}
+
+// genericInterfaceProviderRoutes lists the REST routes configured for
+// GenericInterface provider operations, at the path OTRS serves them from.
+// GoatFlow only acts as a requester, so they are reported as not mounted.
+func genericInterfaceProviderRoutes(webservices []*models.WebserviceConfig) []routing.RouteEntry {
+	var entries []routing.RouteEntry
+	for _, ws := range webservices {
+		if ws.Config == nil {
+			continue
+		}
+		mappings := ws.Config.Provider.Transport.Config.RouteOperationMapping
+		operations := make([]string, 0, len(mappings))
+		for op := range mappings {
+			operations = append(operations, op)
+		}
+		sort.Strings(operations)
+		for _, op := range operations {
+			mapping := mappings[op]
+			methods := mapping.RequestMethod
+			if len(methods) == 0 {
+				methods = []string{"POST"}
+			}
+			description := ""
+			if cfg := ws.GetOperation(op); cfg != nil {
+				description = cfg.Description
+			}
+			for _, method := range methods {
+				entries = append(entries, routing.RouteEntry{
+					Method:      strings.ToUpper(method),
+					Path:        "/otrs/nph-genericinterface.pl/Webservice/" + ws.Name + mapping.Route,
+					Source:      routing.RouteSourceGenericInterface,
+					Module:      ws.Name,
+					Handler:     op,
+					Description: description,
+					Auth:        routing.RouteAuthUnknown,
+				})
+			}
+		}
+	}
+	return entries
+}
@@ -0,0 +1,35 @@
+package api
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/routing"
+)
+
+func TestGenericInterfaceProviderRoutes(t *testing.T) {
+	ws := &models.WebserviceConfig{Name: "Connector", Config: &models.WebserviceConfigData{}}
+	ws.Config.Provider.Operation = map[string]models.OperationConfig{
+		"TicketGet": {Type: "Ticket::TicketGet", Description: "Fetch a ticket"},
+	}
+	ws.Config.Provider.Transport.Config.RouteOperationMapping = map[string]models.RouteMapping{
+		"TicketGet":    {Route: "/Ticket/:TicketID", RequestMethod: []string{"get"}},
+		"TicketCreate": {Route: "/Ticket"},
+	}
+
+	entries := genericInterfaceProviderRoutes([]*models.WebserviceConfig{ws, {Name: "Empty"}})
+	require.Len(t, entries, 2)
+
+	assert.Equal(t, "POST", entries[0].Method)
+	assert.Equal(t, "/otrs/nph-genericinterface.pl/Webservice/Connector/Ticket", entries[0].Path)
+	assert.Equal(t, "TicketCreate", entries[0].Handler)
+
+	assert.Equal(t, "GET", entries[1].Method)
+	assert.Equal(t, "Fetch a ticket", entries[1].Description)
+	assert.Equal(t, routing.RouteSourceGenericInterface, entries[1].Source)
+	assert.Equal(t, "Connector", entries[1].Module)
+	assert.False(t, entries[1].Mounted)
+}
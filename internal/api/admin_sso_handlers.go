@@ -0,0 +1,361 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/flosch/pongo2/v6"
+	"github.com/gin-gonic/gin"
+
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/service"
+)
+
+// ssoProviderRequest is the JSON body accepted by the provider create/update handlers.
+type ssoProviderRequest struct {
+	Name            string                   `json:"name" binding:"required"`
+	Protocol        models.SSOProtocol       `json:"protocol" binding:"required"`
+	Frontend        models.SSOFrontend       `json:"frontend" binding:"required"`
+	Config          models.SSOProviderConfig `json:"config"`
+	GroupMappings   []models.SSOGroupMapping `json:"group_mappings"`
+	JITProvisioning bool                     `json:"jit_provisioning"`
+	ValidID         int                      `json:"valid_id"`
+}
+
+func (r *ssoProviderRequest) provider() *models.SSOProvider {
+	return &models.SSOProvider{
+		Name:            r.Name,
+		Protocol:        r.Protocol,
+		Frontend:        r.Frontend,
+		Config:          r.Config,
+		GroupMappings:   r.GroupMappings,
+		JITProvisioning: r.JITProvisioning,
+		ValidID:         r.ValidID,
+	}
+}
+
+func ssoService(c *gin.Context) *service.SSOService {
+	db, err := database.GetDB()
+	if err != nil || db == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"success": false, "error": "Database unavailable"})
+		return nil
+	}
+	return service.NewSSOService(db)
+}
+
+// ssoWriteError maps SSOService errors to responses.
+func ssoWriteError(c *gin.Context, err error, action string) {
+	switch {
+	case errors.Is(err, service.ErrSSOProviderNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "SSO provider not found"})
+	case errors.Is(err, service.ErrSSOLinkNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "SSO link not found"})
+	case errors.Is(err, service.ErrSSONameExists), errors.Is(err, service.ErrSSOSubjectLinked):
+		c.JSON(http.StatusConflict, gin.H{"success": false, "error": err.Error()})
+	case errors.Is(err, service.ErrSSONameRequired),
+		errors.Is(err, service.ErrSSONoSubject),
+		errors.Is(err, service.ErrSSOAccountNotFound),
+		errors.Is(err, service.ErrSSOInvalidProtocol),
+		errors.Is(err, service.ErrSSOInvalidFrontend),
+		errors.Is(err, service.ErrSSOIncompleteConfig),
+		errors.Is(err, service.ErrSSOInvalidMapping):
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+	default:
+		log.Printf("sso api: %s failed: %v", action, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to " + action})
+	}
+}
+
+// ssoProviderID parses the :id path parameter, writing 400 when it is invalid.
+func ssoProviderID(c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid SSO provider ID"})
+		return 0, false
+	}
+	return id, true
+}
+
+// handleAdminSSOProviders renders the single sign-on provider list.
+func handleAdminSSOProviders(c *gin.Context) {
+	db, err := database.GetDB()
+	if err != nil || db == nil {
+		sendErrorResponse(c, http.StatusServiceUnavailable, "Database unavailable")
+		return
+	}
+
+	table := newAdminTable(c, "sso-table", "admin.sso.title", "/admin/sso", []adminTableColumn{
+		{Key: "name", Label: "admin.sso.name"},
+		{Key: "protocol", Label: "admin.sso.protocol"},
+		{Key: "frontend", Label: "admin.sso.frontend"},
+		{Label: "admin.sso.jit_provisioning"},
+		{Key: "status", Label: "common.status"},
+		{Label: "common.actions"},
+	}, "name", "search")
+
+	providers, err := service.NewSSOService(db).ListProviders(c.Request.Context())
+	if err != nil {
+		sendErrorResponse(c, http.StatusInternalServerError, "Failed to fetch SSO providers")
+		return
+	}
+
+	search := strings.ToLower(table.Filter("search"))
+	filtered := make([]models.SSOProvider, 0, len(providers))
+	for _, p := range providers {
+		if search != "" && !strings.Contains(strings.ToLower(p.Name), search) {
+			continue
+		}
+		filtered = append(filtered, p.Redacted())
+	}
+
+	if strings.Contains(c.GetHeader("Accept"), "application/json") {
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": filtered})
+		return
+	}
+
+	filtered = paginateAdminTable(table, filtered, adminSSOSortValue)
+
+	getPongo2Renderer().HTML(c, http.StatusOK, "pages/admin/sso_providers.pongo2", pongo2.Context{
+		"Title":       "Single Sign-On",
+		"Providers":   filtered,
+		"SearchQuery": table.Filter("search"),
+		"Table":       table,
+		"User":        getUserMapForTemplate(c),
+		"ActivePage":  "admin",
+	})
+}
+
+func adminSSOSortValue(p models.SSOProvider, key string) any {
+	switch key {
+	case "protocol":
+		return string(p.Protocol)
+	case "frontend":
+		return string(p.Frontend)
+	case "status":
+		return p.ValidID
+	}
+	return p.Name
+}
+
+// handleAdminSSOProviderNew renders the new provider form.
+func handleAdminSSOProviderNew(c *gin.Context) {
+	renderSSOProviderForm(c, nil)
+}
+
+// handleAdminSSOProviderEdit renders the provider edit form.
+func handleAdminSSOProviderEdit(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		sendErrorResponse(c, http.StatusBadRequest, "Invalid SSO provider ID")
+		return
+	}
+	db, err := database.GetDB()
+	if err != nil || db == nil {
+		sendErrorResponse(c, http.StatusServiceUnavailable, "Database unavailable")
+		return
+	}
+	p, err := service.NewSSOService(db).GetProvider(c.Request.Context(), id)
+	if errors.Is(err, service.ErrSSOProviderNotFound) {
+		sendErrorResponse(c, http.StatusNotFound, "SSO provider not found")
+		return
+	}
+	if err != nil {
+		sendErrorResponse(c, http.StatusInternalServerError, "Failed to fetch SSO provider")
+		return
+	}
+	redacted := p.Redacted()
+	renderSSOProviderForm(c, &redacted)
+}
+
+// renderSSOProviderForm renders the provider form. The provider is handed to
+// the page as JSON because certificates and secrets do not survive being
+// pasted into JavaScript literals.
+func renderSSOProviderForm(c *gin.Context, p *models.SSOProvider) {
+	db, _ := database.GetDB()
+	form := p
+	if form == nil {
+		form = &models.SSOProvider{Protocol: models.SSOProtocolOIDC, Frontend: models.SSOFrontendAgent, ValidID: 1}
+	}
+	if form.GroupMappings == nil {
+		form.GroupMappings = []models.SSOGroupMapping{}
+	}
+	payload, err := json.Marshal(form)
+	if err != nil {
+		sendErrorResponse(c, http.StatusInternalServerError, "Failed to render SSO provider")
+		return
+	}
+	ctx := pongo2.Context{
+		"Title":        "New SSO Provider",
+		"IsNew":        p == nil,
+		"Provider":     p,
+		"ProviderJSON": string(payload),
+		"Groups":       loadGroupsForForm(c.Request.Context(), db),
+		"User":         getUserMapForTemplate(c),
+		"ActivePage":   "admin",
+	}
+	if p != nil {
+		base := fmt.Sprintf("%s/auth/sso/%d", ssoBaseURL(c), p.ID)
+		ctx["Title"] = "Edit SSO Provider"
+		ctx["CallbackURL"] = base + "/callback"
+		ctx["ACSURL"] = base + "/acs"
+		ctx["MetadataURL"] = base + "/metadata"
+	}
+	getPongo2Renderer().HTML(c, http.StatusOK, "pages/admin/sso_provider_form.pongo2", ctx)
+}
+
+// handleAdminSSOProviderGet returns a provider as JSON, without its client secret.
+func handleAdminSSOProviderGet(c *gin.Context) {
+	id, ok := ssoProviderID(c)
+	if !ok {
+		return
+	}
+	svc := ssoService(c)
+	if svc == nil {
+		return
+	}
+	p, err := svc.GetProvider(c.Request.Context(), id)
+	if err != nil {
+		ssoWriteError(c, err, "fetch SSO provider")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": p.Redacted()})
+}
+
+// handleCreateSSOProvider creates a provider.
+func handleCreateSSOProvider(c *gin.Context) {
+	var req ssoProviderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid request: " + err.Error()})
+		return
+	}
+	svc := ssoService(c)
+	if svc == nil {
+		return
+	}
+	p := req.provider()
+	p.CreateBy = GetUserIDFromCtx(c, 1)
+	if err := svc.CreateProvider(c.Request.Context(), p); err != nil {
+		ssoWriteError(c, err, "create SSO provider")
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"success": true, "data": p.Redacted()})
+}
+
+// handleUpdateSSOProvider updates a provider. A client secret sent back as
+// the placeholder keeps the stored secret.
+func handleUpdateSSOProvider(c *gin.Context) {
+	id, ok := ssoProviderID(c)
+	if !ok {
+		return
+	}
+	var req ssoProviderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid request: " + err.Error()})
+		return
+	}
+	svc := ssoService(c)
+	if svc == nil {
+		return
+	}
+	p := req.provider()
+	p.ID = id
+	if err := svc.UpdateProvider(c.Request.Context(), p, GetUserIDFromCtx(c, 1)); err != nil {
+		ssoWriteError(c, err, "update SSO provider")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": p.Redacted()})
+}
+
+// handleDeleteSSOProvider removes a provider. Accounts it provisioned are kept.
+func handleDeleteSSOProvider(c *gin.Context) {
+	id, ok := ssoProviderID(c)
+	if !ok {
+		return
+	}
+	svc := ssoService(c)
+	if svc == nil {
+		return
+	}
+	if err := svc.DeleteProvider(c.Request.Context(), id); err != nil {
+		ssoWriteError(c, err, "delete SSO provider")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "SSO provider deleted"})
+}
+
+// ssoLinkRequest is the JSON body accepted by handleCreateSSOLink.
+type ssoLinkRequest struct {
+	Subject string `json:"subject" binding:"required"`
+	Login   string `json:"login" binding:"required"`
+}
+
+// handleListSSOLinks lists the accounts linked to a provider.
+func handleListSSOLinks(c *gin.Context) {
+	id, ok := ssoProviderID(c)
+	if !ok {
+		return
+	}
+	svc := ssoService(c)
+	if svc == nil {
+		return
+	}
+	links, err := svc.ListLinks(c.Request.Context(), id)
+	if err != nil {
+		ssoWriteError(c, err, "list SSO links")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": links})
+}
+
+// handleCreateSSOLink links an existing account to the provider's subject
+// for a user, so they can sign in to it through the provider.
+func handleCreateSSOLink(c *gin.Context) {
+	id, ok := ssoProviderID(c)
+	if !ok {
+		return
+	}
+	var req ssoLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid request: " + err.Error()})
+		return
+	}
+	svc := ssoService(c)
+	if svc == nil {
+		return
+	}
+	link, err := svc.LinkAccount(c.Request.Context(), id, req.Subject, req.Login, GetUserIDFromCtx(c, 1))
+	if err != nil {
+		ssoWriteError(c, err, "link account")
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"success": true, "data": link})
+}
+
+// handleDeleteSSOLink unlinks the account of the subject given as the
+// subject query parameter. The account is kept.
+func handleDeleteSSOLink(c *gin.Context) {
+	id, ok := ssoProviderID(c)
+	if !ok {
+		return
+	}
+	subject := c.Query("subject")
+	if subject == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "subject is required"})
+		return
+	}
+	svc := ssoService(c)
+	if svc == nil {
+		return
+	}
+	if err := svc.UnlinkAccount(c.Request.Context(), id, subject); err != nil {
+		ssoWriteError(c, err, "unlink account")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Account unlinked"})
+}
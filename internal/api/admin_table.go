@@ -0,0 +1,246 @@
+package api
+
+import (
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	adminTableDefaultPerPage = 25
+	adminTableMaxPerPage     = 100
+	adminTablePageWindow     = 2
+)
+
+// adminTableColumn describes one header cell of a server-rendered admin table.
+// Columns without a Key cannot be sorted.
+type adminTableColumn struct {
+	Key    string
+	Label  string
+	Class  string
+	SROnly bool
+
+	SortURL  string
+	AriaSort string
+	NextDir  string
+}
+
+// adminTablePageLink is one entry of the pagination bar; Gap entries stand
+// for the pages skipped between the current window and the first/last page.
+type adminTablePageLink struct {
+	Number  int
+	URL     string
+	Current bool
+	Gap     bool
+}
+
+// adminTable carries the paging, sorting and filter state of an admin list
+// page. It is built from the request query so every link is a plain GET that
+// works without JavaScript and can be bookmarked.
+type adminTable struct {
+	ID      string
+	Label   string
+	Path    string
+	Filters map[string]string
+	Sort    string
+	Dir     string
+	Page    int
+	PerPage int
+	Total   int
+	Pages   int
+	From    int
+	To      int
+
+	Columns   []adminTableColumn
+	PageLinks []adminTablePageLink
+	PrevURL   string
+	NextURL   string
+
+	filterKeys  []string
+	defaultSort string
+}
+
+// newAdminTable reads sort, dir, page and per_page plus the named filter
+// parameters from the request. Unknown sort keys fall back to defaultSort.
+func newAdminTable(c *gin.Context, id, label, path string, columns []adminTableColumn,
+	defaultSort string, filterKeys ...string) *adminTable {
+	t := &adminTable{
+		ID:          id,
+		Label:       label,
+		Path:        path,
+		Filters:     make(map[string]string, len(filterKeys)),
+		Sort:        defaultSort,
+		Dir:         "asc",
+		Page:        1,
+		PerPage:     adminTableDefaultPerPage,
+		Columns:     columns,
+		filterKeys:  filterKeys,
+		defaultSort: defaultSort,
+	}
+	for _, key := range filterKeys {
+		t.Filters[key] = strings.TrimSpace(c.Query(key))
+	}
+	if key := strings.TrimSpace(c.Query("sort")); key != "" && t.sortable(key) {
+		t.Sort = key
+	}
+	if strings.EqualFold(c.Query("dir"), "desc") {
+		t.Dir = "desc"
+	}
+	if page, err := strconv.Atoi(c.Query("page")); err == nil && page > 0 {
+		t.Page = page
+	}
+	if perPage, err := strconv.Atoi(c.Query("per_page")); err == nil && perPage > 0 {
+		t.PerPage = min(perPage, adminTableMaxPerPage)
+	}
+	return t
+}
+
+func (t *adminTable) sortable(key string) bool {
+	for _, col := range t.Columns {
+		if col.Key != "" && col.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// Filter returns the trimmed value of a filter parameter.
+func (t *adminTable) Filter(key string) string {
+	return t.Filters[key]
+}
+
+// url builds a link back to the list that keeps the active filters.
+func (t *adminTable) url(page int, sortKey, dir string) string {
+	q := url.Values{}
+	for _, key := range t.filterKeys {
+		if v := t.Filters[key]; v != "" {
+			q.Set(key, v)
+		}
+	}
+	if sortKey != t.defaultSort || dir != "asc" {
+		q.Set("sort", sortKey)
+		q.Set("dir", dir)
+	}
+	if t.PerPage != adminTableDefaultPerPage {
+		q.Set("per_page", strconv.Itoa(t.PerPage))
+	}
+	if page > 1 {
+		q.Set("page", strconv.Itoa(page))
+	}
+	if len(q) == 0 {
+		return t.Path
+	}
+	return t.Path + "?" + q.Encode()
+}
+
+// paginateAdminTable sorts rows by the requested column, records the totals
+// and links on t and returns the rows of the current page. value extracts
+// the sort value of a row for a column key.
+func paginateAdminTable[T any](t *adminTable, rows []T, value func(row T, key string) any) []T {
+	sort.SliceStable(rows, func(i, j int) bool {
+		cmp := compareAdminTableValues(value(rows[i], t.Sort), value(rows[j], t.Sort))
+		if t.Dir == "desc" {
+			return cmp > 0
+		}
+		return cmp < 0
+	})
+
+	t.Total = len(rows)
+	t.Pages = max(1, (t.Total+t.PerPage-1)/t.PerPage)
+	t.Page = min(t.Page, t.Pages)
+	start := (t.Page - 1) * t.PerPage
+	end := min(start+t.PerPage, t.Total)
+	if t.Total > 0 {
+		t.From, t.To = start+1, end
+	}
+
+	for i := range t.Columns {
+		col := &t.Columns[i]
+		if col.Key == "" {
+			continue
+		}
+		col.AriaSort, col.NextDir = "none", "asc"
+		if col.Key == t.Sort {
+			if t.Dir == "desc" {
+				col.AriaSort = "descending"
+			} else {
+				col.AriaSort, col.NextDir = "ascending", "desc"
+			}
+		}
+		col.SortURL = t.url(1, col.Key, col.NextDir)
+	}
+
+	t.PageLinks = t.PageLinks[:0]
+	for n := 1; n <= t.Pages; n++ {
+		if n != 1 && n != t.Pages && (n < t.Page-adminTablePageWindow || n > t.Page+adminTablePageWindow) {
+			if last := len(t.PageLinks) - 1; last < 0 || !t.PageLinks[last].Gap {
+				t.PageLinks = append(t.PageLinks, adminTablePageLink{Gap: true})
+			}
+			continue
+		}
+		t.PageLinks = append(t.PageLinks, adminTablePageLink{Number: n, URL: t.url(n, t.Sort, t.Dir), Current: n == t.Page})
+	}
+	t.PrevURL, t.NextURL = "", ""
+	if t.Page > 1 {
+		t.PrevURL = t.url(t.Page-1, t.Sort, t.Dir)
+	}
+	if t.Page < t.Pages {
+		t.NextURL = t.url(t.Page+1, t.Sort, t.Dir)
+	}
+
+	return rows[start:end]
+}
+
+// compareAdminTableValues orders strings case-insensitively, numbers
+// numerically and times chronologically; mismatched types compare equal.
+func compareAdminTableValues(a, b any) int {
+	switch av := a.(type) {
+	case string:
+		if bv, ok := b.(string); ok {
+			return strings.Compare(strings.ToLower(av), strings.ToLower(bv))
+		}
+	case time.Time:
+		if bv, ok := b.(time.Time); ok {
+			return av.Compare(bv)
+		}
+	case bool:
+		if bv, ok := b.(bool); ok && av != bv {
+			if av {
+				return 1
+			}
+			return -1
+		}
+	default:
+		af, aok := adminTableNumber(a)
+		bf, bok := adminTableNumber(b)
+		if aok && bok {
+			switch {
+			case af < bf:
+				return -1
+			case af > bf:
+				return 1
+			}
+		}
+	}
+	return 0
+}
+
+func adminTableNumber(v any) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case float64:
+		return n, true
+	}
+	return 0, false
+}
@@ -0,0 +1,136 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newAdminTableTestContext(query string) *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/admin/things?"+query, nil)
+	return c
+}
+
+func adminTableTestColumns() []adminTableColumn {
+	return []adminTableColumn{
+		{Key: "name", Label: "Name"},
+		{Key: "count", Label: "Count"},
+		{Label: "Actions", SROnly: true},
+	}
+}
+
+type adminTableTestRow struct {
+	Name  string
+	Count int
+}
+
+func adminTableTestValue(r adminTableTestRow, key string) any {
+	if key == "count" {
+		return r.Count
+	}
+	return r.Name
+}
+
+func TestNewAdminTable_ParsesQuery(t *testing.T) {
+	c := newAdminTableTestContext("sort=count&dir=DESC&page=3&per_page=500&search=+abc+")
+	table := newAdminTable(c, "things", "Things", "/admin/things", adminTableTestColumns(), "name", "search")
+
+	assert.Equal(t, "count", table.Sort)
+	assert.Equal(t, "desc", table.Dir)
+	assert.Equal(t, 3, table.Page)
+	assert.Equal(t, adminTableMaxPerPage, table.PerPage)
+	assert.Equal(t, "abc", table.Filter("search"))
+
+	// Unknown or unsortable columns and bad numbers fall back to defaults.
+	c = newAdminTableTestContext("sort=Actions&dir=sideways&page=-1&per_page=x")
+	table = newAdminTable(c, "things", "Things", "/admin/things", adminTableTestColumns(), "name")
+	assert.Equal(t, "name", table.Sort)
+	assert.Equal(t, "asc", table.Dir)
+	assert.Equal(t, 1, table.Page)
+	assert.Equal(t, adminTableDefaultPerPage, table.PerPage)
+}
+
+func TestPaginateAdminTable(t *testing.T) {
+	rows := []adminTableTestRow{{"delta", 4}, {"Alpha", 9}, {"charlie", 1}, {"bravo", 7}, {"echo", 2}}
+
+	c := newAdminTableTestContext("sort=count&dir=desc&per_page=2&page=2&search=a")
+	table := newAdminTable(c, "things", "Things", "/admin/things", adminTableTestColumns(), "name", "search")
+	page := paginateAdminTable(table, rows, adminTableTestValue)
+
+	assert.Equal(t, []adminTableTestRow{{"delta", 4}, {"echo", 2}}, page)
+	assert.Equal(t, 5, table.Total)
+	assert.Equal(t, 3, table.Pages)
+	assert.Equal(t, 3, table.From)
+	assert.Equal(t, 4, table.To)
+	assert.Equal(t, "/admin/things?dir=desc&per_page=2&search=a&sort=count", table.PrevURL)
+	assert.Equal(t, "/admin/things?dir=desc&page=3&per_page=2&search=a&sort=count", table.NextURL)
+
+	require.Len(t, table.PageLinks, 3)
+	assert.True(t, table.PageLinks[1].Current)
+	assert.Equal(t, 2, table.PageLinks[1].Number)
+
+	// The active column flips direction; others offer ascending.
+	assert.Equal(t, "none", table.Columns[0].AriaSort)
+	assert.Equal(t, "/admin/things?per_page=2&search=a", table.Columns[0].SortURL, "default order drops sort and dir")
+	assert.Equal(t, "descending", table.Columns[1].AriaSort)
+	assert.Equal(t, "/admin/things?dir=asc&per_page=2&search=a&sort=count", table.Columns[1].SortURL)
+	assert.Empty(t, table.Columns[2].AriaSort)
+	assert.Empty(t, table.Columns[2].SortURL)
+}
+
+func TestPaginateAdminTable_ClampsPageAndGapsLinks(t *testing.T) {
+	rows := make([]adminTableTestRow, 95)
+	for i := range rows {
+		rows[i] = adminTableTestRow{Count: i}
+	}
+
+	c := newAdminTableTestContext("sort=count&per_page=10&page=99")
+	table := newAdminTable(c, "things", "Things", "/admin/things", adminTableTestColumns(), "name")
+	page := paginateAdminTable(table, rows, adminTableTestValue)
+
+	assert.Equal(t, 10, table.Page)
+	assert.Len(t, page, 5)
+	assert.Empty(t, table.NextURL)
+	assert.Equal(t, "ascending", table.Columns[1].AriaSort)
+
+	var numbers []int
+	for _, link := range table.PageLinks {
+		numbers = append(numbers, link.Number)
+	}
+	assert.Equal(t, []int{1, 0, 8, 9, 10}, numbers, "0 marks a gap")
+
+	empty := newAdminTable(newAdminTableTestContext(""), "things", "Things", "/admin/things", adminTableTestColumns(), "name")
+	assert.Empty(t, paginateAdminTable(empty, []adminTableTestRow{}, adminTableTestValue))
+	assert.Equal(t, 1, empty.Pages)
+	assert.Zero(t, empty.From)
+	assert.Equal(t, "/admin/things", empty.PageLinks[0].URL)
+}
+
+func TestHandleQueues_ServerSideTable(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("HTMX_HANDLER_TEST_MODE", "1")
+	SetupTestTemplateRenderer(t)
+	if getPongo2Renderer() == nil {
+		t.Skip("templates not available")
+	}
+
+	router := gin.New()
+	router.GET("/queues", handleQueues)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/queues?sort=total&dir=desc&per_page=2", nil))
+
+	require.Equal(t, http.StatusOK, w.Code)
+	body := w.Body.String()
+	assert.Contains(t, body, "General Support")
+	assert.Contains(t, body, "Technical Support")
+	assert.NotContains(t, body, "Invoices and refunds", "third queue is on page 2")
+	assert.Contains(t, body, `aria-sort="descending"`)
+	assert.Contains(t, body, `aria-current="page"`)
+	assert.Contains(t, body, `href="/queues?dir=desc&amp;page=2&amp;per_page=2&amp;sort=total"`)
+	assert.Contains(t, body, `<input type="hidden" name="sort" value="total">`)
+}
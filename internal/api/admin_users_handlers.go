@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"slices"
 	"strconv"
 	"strings"
 
@@ -14,6 +15,7 @@ import (
 
 	"github.com/goatkit/goatflow/internal/database"
 	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/services"
 	"github.com/goatkit/goatflow/internal/services/adapter"
 	"github.com/goatkit/goatflow/internal/shared"
 )
@@ -113,6 +115,29 @@ func HandleAdminUsers(c *gin.Context) {
 		}
 	}
 
+	table := newAdminTable(c, "users-table", "admin.users", "/admin/users", []adminTableColumn{
+		{Key: "name", Label: "admin.user_name"},
+		{Key: "login", Label: "admin.login"},
+		{Key: "groups", Label: "admin.groups"},
+		{Key: "status", Label: "admin.status"},
+		{Label: "admin.2fa"},
+		{Label: "admin.last_login"},
+		{Label: "admin.actions", Class: "relative px-6 py-3", SROnly: true},
+	}, "name", "search", "status", "group")
+	groupName := ""
+	for _, g := range groups {
+		if fmt.Sprint(g["ID"]) == table.Filter("group") {
+			groupName, _ = g["Name"].(string)
+		}
+	}
+	filtered := users[:0]
+	for _, u := range users {
+		if adminUserMatches(u, table.Filter("search"), table.Filter("status"), groupName, table.Filter("group") != "") {
+			filtered = append(filtered, u)
+		}
+	}
+	users = paginateAdminTable(table, filtered, adminUserSortValue)
+
 	// Render template
 	renderer := shared.GetGlobalRenderer()
 	if renderer == nil {
@@ -129,12 +154,47 @@ func HandleAdminUsers(c *gin.Context) {
 		"Title":          "Users",
 		"Users":          users,
 		"Groups":         groups,
+		"Table":          table,
 		"User":           user,
 		"IsInAdminGroup": isInAdminGroup,
 		"ActivePage":     "admin",
 	})
 }
 
+// adminUserMatches applies the users list filters: search text over name,
+// login and group names, status by valid_id and membership of one group.
+func adminUserMatches(u gin.H, search, status, groupName string, byGroup bool) bool {
+	groups, _ := u["Groups"].([]string)
+	if search != "" {
+		needle := strings.ToLower(search)
+		haystack := strings.ToLower(fmt.Sprintf("%v %v %v %v", u["FirstName"], u["LastName"], u["Login"], strings.Join(groups, " ")))
+		if !strings.Contains(haystack, needle) {
+			return false
+		}
+	}
+	valid, _ := u["ValidID"].(int)
+	if (status == "1" && valid != 1) || (status == "2" && valid == 1) {
+		return false
+	}
+	if byGroup {
+		return groupName != "" && slices.Contains(groups, groupName)
+	}
+	return true
+}
+
+func adminUserSortValue(u gin.H, key string) any {
+	switch key {
+	case "login":
+		return u["Login"]
+	case "groups":
+		groups, _ := u["Groups"].([]string)
+		return strings.Join(groups, ", ")
+	case "status":
+		return u["ValidID"]
+	}
+	return fmt.Sprintf("%v %v", u["LastName"], u["FirstName"])
+}
+
 // HandleAdminUserGet handles GET /admin/users/:id.
 func HandleAdminUserGet(c *gin.Context) {
 	userID := c.Param("id")
@@ -614,6 +674,7 @@ func HandleAdminUserUpdate(c *gin.Context) {
 			})
 			return
 		}
+		services.InvalidateUserPermissions(id)
 	}
 
 	c.JSON(http.StatusOK, gin.H{
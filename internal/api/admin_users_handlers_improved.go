@@ -11,6 +11,7 @@ import (
 
 	"github.com/goatkit/goatflow/internal/database"
 	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/services"
 )
 
 // ImprovedHandleAdminUserGet handles GET /admin/users/:id with enhanced error handling and logging.
@@ -307,6 +308,7 @@ func ImprovedHandleAdminUserUpdate(c *gin.Context) {
 		})
 		return
 	}
+	services.InvalidateUserPermissions(id)
 
 	// Final verification - query the actual groups from database
 	var finalGroups []string
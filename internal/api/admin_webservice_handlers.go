@@ -41,9 +41,22 @@ func handleAdminWebservices(c *gin.Context) {
 		return
 	}
 
+	table := newAdminTable(c, "webservices-table", "admin.webservices.title", "/admin/webservices", []adminTableColumn{
+		{Key: "id", Label: "ID"},
+		{Key: "name", Label: "admin.webservices.name"},
+		{Label: "admin.webservices.description"},
+		{Key: "transport", Label: "admin.webservices.transport"},
+		{Key: "remote_system", Label: "admin.webservices.remote_system"},
+		{Key: "status", Label: "common.status"},
+		{Label: "common.actions"},
+	}, "name", "search", "valid")
+
 	// Get search and filter parameters
-	searchQuery := c.Query("search")
-	validFilter := c.DefaultQuery("valid", "all")
+	searchQuery := table.Filter("search")
+	validFilter := table.Filter("valid")
+	if validFilter == "" {
+		validFilter = "all"
+	}
 
 	// Get all webservices
 	webservices, err := svc.ListWebservices(c.Request.Context())
@@ -88,17 +101,40 @@ func handleAdminWebservices(c *gin.Context) {
 		return
 	}
 
+	filtered = paginateAdminTable(table, filtered, adminWebserviceSortValue)
+
 	// Render the template
 	getPongo2Renderer().HTML(c, http.StatusOK, "pages/admin/webservices.pongo2", pongo2.Context{
 		"Title":        "Web Services",
 		"Webservices":  filtered,
 		"SearchQuery":  searchQuery,
 		"ValidFilter":  validFilter,
+		"Table":        table,
 		"User":         getUserMapForTemplate(c),
 		"ActivePage":   "admin",
 	})
 }
 
+func adminWebserviceSortValue(ws *models.WebserviceConfig, key string) any {
+	switch key {
+	case "id":
+		return ws.ID
+	case "status":
+		return ws.ValidID
+	case "transport":
+		if ws.Config != nil {
+			return ws.Config.Requester.Transport.Type
+		}
+		return ""
+	case "remote_system":
+		if ws.Config != nil {
+			return ws.Config.RemoteSystem
+		}
+		return ""
+	}
+	return ws.Name
+}
+
 // handleAdminWebserviceNew renders the new webservice form.
 func handleAdminWebserviceNew(c *gin.Context) {
 	// Render the form template
@@ -0,0 +1,87 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/service"
+)
+
+// HandleAgentStatsAPI handles GET /api/v1/stats/agents.
+//
+//	@Summary		Agent performance statistics
+//	@Description	Returns per agent the tickets resolved, the share of them reopened, the average accounted handle time and the SLA compliance over the completed days of a window, optionally per queue or team. format=csv returns a CSV download. Only admins and members of the stats group may view it.
+//	@Tags			Statistics
+//	@Produce		json
+//	@Produce		text/csv
+//	@Param			window		query		string	false	"Number of days such as 7d or 30d, up to 365d (default 30d)"
+//	@Param			group_by	query		string	false	"queue or team"
+//	@Param			format		query		string	false	"json (default) or csv"
+//	@Success		200			{object}	map[string]interface{}	"Agent statistics"
+//	@Failure		400			{object}	map[string]interface{}	"Invalid window, grouping or format"
+//	@Failure		403			{object}	map[string]interface{}	"Not a member of the stats group"
+//	@Security		BearerAuth
+//	@Router			/stats/agents [get]
+func HandleAgentStatsAPI(c *gin.Context) {
+	if attachmentRole(c) == service.AttachmentRoleCustomer {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": "Agent statistics are only available to agents"})
+		return
+	}
+	userID := GetUserIDFromCtx(c, 0)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"success": false, "error": "Authentication required"})
+		return
+	}
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "csv" {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "format must be json or csv"})
+		return
+	}
+	db, err := database.GetDB()
+	if err != nil || db == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"success": false, "error": "Database unavailable"})
+		return
+	}
+
+	svc := service.NewAgentStatsService(db)
+	ctx := c.Request.Context()
+	ok, err := svc.CanView(ctx, userID)
+	if err != nil {
+		log.Printf("agent stats api: check permissions: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to check permissions"})
+		return
+	}
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": "Agent statistics require membership of the stats group"})
+		return
+	}
+
+	report, err := svc.Stats(ctx, c.Query("window"), models.AgentStatsGrouping(c.Query("group_by")))
+	if errors.Is(err, service.ErrStatsWindow) || errors.Is(err, service.ErrAgentStatsGrouping) {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Printf("agent stats api: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to load agent statistics"})
+		return
+	}
+
+	if format == "csv" {
+		data, err := service.RenderAgentStatsCSV(report)
+		if err != nil {
+			log.Printf("agent stats api: render csv: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to render agent statistics"})
+			return
+		}
+		c.Header("Content-Disposition", `attachment; filename="agent-stats-`+report.To+`.csv"`)
+		c.Data(http.StatusOK, "text/csv; charset=utf-8", data)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": report})
+}
@@ -0,0 +1,74 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/testutil"
+)
+
+func TestHandleAgentStatsAPI(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := testutil.UseMigratedDB(t)
+	for _, stmt := range []string{
+		`INSERT INTO users (id, login, pw, first_name, last_name, valid_id, create_time, create_by, change_time, change_by) VALUES
+			(2, 'alice', 'x', 'Alice', 'Agent', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1),
+			(3, 'carol', 'x', 'Carol', 'Lead', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+		`INSERT INTO group_user (user_id, group_id, permission_key, create_time, create_by, change_time, change_by)
+			SELECT 3, id, 'ro', CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1 FROM groups WHERE name = 'stats'`,
+	} {
+		_, err := db.Exec(stmt)
+		require.NoError(t, err, stmt)
+	}
+
+	get := func(path string, userID int, customer bool) *httptest.ResponseRecorder {
+		router := gin.New()
+		router.Use(func(c *gin.Context) {
+			c.Set("user_id", userID)
+			c.Set("is_customer", customer)
+			c.Next()
+		})
+		router.GET("/api/v1/stats/agents", HandleAgentStatsAPI)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	assert.Equal(t, http.StatusForbidden, get("/api/v1/stats/agents", 5, true).Code, "customers are refused")
+	assert.Equal(t, http.StatusUnauthorized, get("/api/v1/stats/agents", 0, false).Code)
+	assert.Equal(t, http.StatusForbidden, get("/api/v1/stats/agents", 2, false).Code, "not in the stats group")
+	assert.Equal(t, http.StatusBadRequest, get("/api/v1/stats/agents?format=xml", 3, false).Code)
+	assert.Equal(t, http.StatusBadRequest, get("/api/v1/stats/agents?window=1w", 3, false).Code)
+	w := get("/api/v1/stats/agents?group_by=owner", 3, false)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "group_by")
+
+	w = get("/api/v1/stats/agents?window=7d&group_by=queue", 3, false)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var body struct {
+		Success bool `json:"success"`
+		Data    struct {
+			Window  string        `json:"window"`
+			GroupBy string        `json:"group_by"`
+			Agents  []interface{} `json:"agents"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.True(t, body.Success)
+	assert.Equal(t, "7d", body.Data.Window)
+	assert.Equal(t, "queue", body.Data.GroupBy)
+	assert.NotNil(t, body.Data.Agents)
+
+	w = get("/api/v1/stats/agents?format=csv&group_by=team", 3, false)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/csv; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Header().Get("Content-Disposition"), `filename="agent-stats-`)
+	assert.True(t, strings.HasPrefix(w.Body.String(), "Agent ID,Login,Name,Team ID,Team,Resolved"))
+}
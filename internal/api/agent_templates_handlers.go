@@ -170,8 +170,7 @@ func handleGetAgentTemplate(c *gin.Context) {
 	if ticketIDStr != "" {
 		ticketID, err := strconv.Atoi(ticketIDStr)
 		if err == nil {
-			vars := GetTicketTemplateVariables(ticketID)
-			template.Text = SubstituteTemplateVariables(template.Text, vars)
+			template.Text = ExpandTemplatePlaceholders(template.Text, ticketID, GetUserIDFromCtx(c, 0))
 		}
 	}
 
@@ -263,6 +262,39 @@ func GetTicketTemplateVariables(ticketID int) map[string]string {
 	return vars
 }
 
+// AddCurrentUserTemplateVariables fills the CURRENT_* variables for the agent
+// composing the reply. Unknown users leave the variables untouched.
+func AddCurrentUserTemplateVariables(vars map[string]string, userID int) {
+	if userID <= 0 {
+		return
+	}
+	db, err := database.GetDB()
+	if err != nil || db == nil {
+		return
+	}
+
+	var login, firstName, lastName sql.NullString
+	err = db.QueryRow(database.ConvertPlaceholders(`
+		SELECT login, first_name, last_name FROM users WHERE id = ?
+	`), userID).Scan(&login, &firstName, &lastName)
+	if err != nil {
+		return
+	}
+
+	vars["CURRENT_UserLogin"] = login.String
+	vars["CURRENT_UserFirstname"] = firstName.String
+	vars["CURRENT_UserLastname"] = lastName.String
+	vars["CURRENT_UserFullname"] = strings.TrimSpace(firstName.String + " " + lastName.String)
+}
+
+// ExpandTemplatePlaceholders substitutes ticket, customer and agent
+// placeholders in text for the given ticket and agent.
+func ExpandTemplatePlaceholders(text string, ticketID, userID int) string {
+	vars := GetTicketTemplateVariables(ticketID)
+	AddCurrentUserTemplateVariables(vars, userID)
+	return SubstituteTemplateVariables(text, vars)
+}
+
 // AttachmentInfo represents minimal attachment info for agent API.
 type AttachmentInfo struct {
 	ID          int    `json:"id"`
@@ -21,6 +21,7 @@ import (
 	"github.com/goatkit/goatflow/internal/models"
 	"github.com/goatkit/goatflow/internal/notifications"
 	"github.com/goatkit/goatflow/internal/repository"
+	"github.com/goatkit/goatflow/internal/service"
 	"github.com/goatkit/goatflow/internal/utils"
 )
 
@@ -248,12 +249,28 @@ func handleAgentTicketReply(db *sql.DB) gin.HandlerFunc {
 			return
 		}
 
+		// Refuse the reply when an attachment breaks the uploader's policy
+		if c.Request.MultipartForm != nil {
+			policySvc := service.NewAttachmentUploadService(db)
+			policy := attachmentPolicy(db, attachmentRole(c))
+			for _, fh := range c.Request.MultipartForm.File["attachments"] {
+				if err := policySvc.Check(c.Request.Context(), policy, tid, normalizeMimeType(fh.Header.Get("Content-Type")), fh.Size); err != nil {
+					status, msg, _ := attachmentPolicyMessage(err)
+					c.JSON(status, gin.H{"error": fh.Filename + ": " + msg})
+					return
+				}
+			}
+		}
+
 		// Get user info
 		userID := c.GetUint("user_id")
 		userName := c.GetString("user_name")
 		if userName == "" {
 			userName = "Agent"
 		}
+		if !checkConcurrentReply(c, db, tid, int(userID)) {
+			return
+		}
 
 		// Sanitize HTML content if detected
 		contentType := "text/plain"
@@ -329,6 +346,18 @@ func handleAgentTicketReply(db *sql.DB) gin.HandlerFunc {
 					contentType = http.DetectContentType(content)
 				}
 
+				if err := scanAttachment(c.Request.Context(), db, service.AttachmentScanInput{
+					TicketID:    tid,
+					ArticleID:   int(articleID),
+					Filename:    fileHeader.Filename,
+					ContentType: contentType,
+					Content:     content,
+					UserID:      int(userID),
+				}); err != nil {
+					log.Printf("Attachment %s not stored: %v", fileHeader.Filename, err)
+					continue
+				}
+
 				// Insert attachment - adapter handles placeholder conversion and arg remapping
 				attachmentInsert := `
 					INSERT INTO article_data_mime_attachment (
@@ -361,6 +390,7 @@ func handleAgentTicketReply(db *sql.DB) gin.HandlerFunc {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save reply"})
 			return
 		}
+		discardArticleDraft(c.Request.Context(), db, int64(tid), int(userID))
 
 		c.JSON(http.StatusOK, gin.H{"success": true, "article_id": articleID})
 	}
@@ -477,6 +507,9 @@ func handleAgentTicketNote(db *sql.DB) gin.HandlerFunc {
 
 		// Get user info
 		userID := c.GetUint("user_id")
+		if isVisibleForCustomer == 1 && !checkConcurrentReply(c, db, tid, int(userID)) {
+			return
+		}
 
 		// Sanitize HTML content if detected
 		contentType := "text/plain"
@@ -562,6 +595,7 @@ func handleAgentTicketNote(db *sql.DB) gin.HandlerFunc {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save note"})
 			return
 		}
+		discardArticleDraft(c.Request.Context(), db, int64(tid), int(userID))
 
 		// Process file attachments (after commit so article exists)
 		if form, err := c.MultipartForm(); err == nil && form != nil {
@@ -574,6 +608,7 @@ func handleAgentTicketNote(db *sql.DB) gin.HandlerFunc {
 					ticketID:  tid,
 					articleID: int(articleID),
 					userID:    int(userID),
+					role:      attachmentRole(c),
 				})
 			}
 		}
@@ -817,8 +852,25 @@ func handleAgentTicketStatus(db *sql.DB) gin.HandlerFunc {
 			untilTime = 0
 		}
 
+		tid, err := strconv.Atoi(ticketID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ticket ID"})
+			return
+		}
+		stateID, err := strconv.Atoi(statusID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status"})
+			return
+		}
+		if rejectTicketTypeTransition(c, db, tid, stateID) {
+			return
+		}
+		if requestTicketApproval(c, db, models.ApprovalActionClose, tid, stateID, int(c.GetUint("user_id")), "") {
+			return
+		}
+
 		// Update ticket status with pending time
-		_, err := db.Exec(database.ConvertPlaceholders(`
+		_, err = db.Exec(database.ConvertPlaceholders(`
 			UPDATE ticket 
 			SET ticket_state_id = ?, until_time = ?, change_time = CURRENT_TIMESTAMP, change_by = ?
 			WHERE id = ?
@@ -869,6 +921,13 @@ func handleAgentTicketAssign(db *sql.DB) gin.HandlerFunc {
 			return
 		}
 
+		// Agents who are out of office hand new work to their substitute
+		requestedID := agentID
+		agentID, ok := resolveAssignee(c, service.NewOutOfOfficeService(db), agentID)
+		if !ok {
+			return
+		}
+
 		// Log the assignment for debugging
 		currentUserID := c.GetUint("user_id")
 
@@ -886,7 +945,11 @@ func handleAgentTicketAssign(db *sql.DB) gin.HandlerFunc {
 		}
 
 		log.Printf("SUCCESS: Assigned ticket %s to agent %d", ticketID, agentID)
-		c.JSON(http.StatusOK, gin.H{"success": true})
+		resp := gin.H{"success": true, "agent_id": agentID}
+		if agentID != requestedID {
+			resp["substitute_for"] = requestedID
+		}
+		c.JSON(http.StatusOK, resp)
 	}
 }
 
@@ -917,8 +980,22 @@ func handleAgentTicketQueue(db *sql.DB) gin.HandlerFunc {
 		ticketID := c.Param("id")
 		queueID := c.PostForm("queue_id")
 
+		tid, err := strconv.Atoi(ticketID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ticket ID"})
+			return
+		}
+		targetQueueID, err := strconv.Atoi(queueID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid queue"})
+			return
+		}
+		if requestTicketApproval(c, db, models.ApprovalActionMove, tid, targetQueueID, int(c.GetUint("user_id")), "") {
+			return
+		}
+
 		// Update ticket queue
-		_, err := db.Exec(database.ConvertPlaceholders(`
+		_, err = db.Exec(database.ConvertPlaceholders(`
 			UPDATE ticket 
 			SET queue_id = ?, change_time = CURRENT_TIMESTAMP, change_by = ?
 			WHERE id = ?
@@ -1009,32 +1086,31 @@ func handleAgentTicketMerge(db *sql.DB) gin.HandlerFunc {
 	}
 }
 
-// handleAgentTicketDraft saves a draft reply for a ticket.
+// handleAgentTicketDraft autosaves the agent's draft reply for a ticket.
 func handleAgentTicketDraft(db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		ticketID := c.Param("id")
-		userID, _ := c.Get("user_id")
-
-		var request struct {
-			Subject     string `json:"subject"`
-			Body        string `json:"body"`
-			To          string `json:"to"`
-			Cc          string `json:"cc"`
-			Bcc         string `json:"bcc"`
-			ContentType string `json:"content_type"`
+		ticketID, ok := articleDraftTicketID(c)
+		if !ok {
+			return
 		}
 
-		if err := c.ShouldBindJSON(&request); err != nil {
+		// The reply form posts form data; API clients may send JSON.
+		var request articleDraftRequest
+		if err := c.ShouldBind(&request); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
 			return
 		}
 
-		log.Printf("Draft saved for ticket %s by user %v: subject='%s', body length=%d",
-			ticketID, userID, request.Subject, len(request.Body))
+		draft := request.draft(ticketID)
+		if err := service.NewArticleDraftService(db).Save(c.Request.Context(), draft, GetUserIDFromCtx(c, 1)); err != nil {
+			articleDraftError(c, err, "save draft")
+			return
+		}
 
 		c.JSON(http.StatusOK, gin.H{
 			"success": true,
 			"message": "Draft saved successfully",
+			"data":    draft,
 		})
 	}
 }
@@ -2,6 +2,7 @@ package api
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -13,11 +14,15 @@ import (
 	"github.com/goatkit/goatflow/internal/database"
 	"github.com/goatkit/goatflow/internal/history"
 	"github.com/goatkit/goatflow/internal/repository"
+	"github.com/goatkit/goatflow/internal/service"
 )
 
 // BulkActionRequest represents a request for bulk ticket operations
 type BulkActionRequest struct {
 	TicketIDs []int `json:"ticket_ids" form:"ticket_ids"`
+	// DryRun returns the predicted per-ticket outcome without changing
+	// anything. Supported by the status, priority, queue and assign actions.
+	DryRun bool `json:"dry_run" form:"dry_run"`
 }
 
 // BulkStatusRequest represents a bulk status change request
@@ -66,6 +71,51 @@ type BulkActionResult struct {
 	Errors    []string `json:"errors,omitempty"`
 }
 
+// planBulkChange validates a bulk change for the current agent, writing 400
+// when a target is invalid.
+func planBulkChange(c *gin.Context, db *sql.DB, change service.BulkChange) *service.BulkChangePlan {
+	plan, err := service.NewBulkChangeService(db).Plan(c.Request.Context(), int(c.GetUint("user_id")), change)
+	if errors.Is(err, service.ErrBulkChangeInvalidTarget) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil
+	}
+	if err != nil {
+		log.Printf("Bulk change planning failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check tickets"})
+		return nil
+	}
+	return plan
+}
+
+// writeBulkPreview answers a dry run with the predicted outcome per ticket.
+func writeBulkPreview(c *gin.Context, plan *service.BulkChangePlan, ticketIDs []int) {
+	preview, err := plan.Preview(c.Request.Context(), ticketIDs)
+	if err != nil {
+		log.Printf("Bulk change preview failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to preview changes"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "dry_run": true, "data": preview})
+}
+
+// checkBulkTicket runs the per-ticket checks of plan before a change,
+// recording the failure in result when the ticket is blocked.
+func checkBulkTicket(c *gin.Context, plan *service.BulkChangePlan, ticketID int, result *BulkActionResult) (service.BulkChangePrediction, bool) {
+	pred, err := plan.Check(c.Request.Context(), ticketID)
+	if err != nil {
+		log.Printf("Bulk change check failed for ticket %d: %v", ticketID, err)
+		result.Failed++
+		result.Errors = append(result.Errors, fmt.Sprintf("Ticket %d: check failed", ticketID))
+		return pred, false
+	}
+	if pred.Outcome == service.BulkChangeBlocked {
+		result.Failed++
+		result.Errors = append(result.Errors, fmt.Sprintf("Ticket %d: %s", ticketID, strings.Join(pred.Reasons, "; ")))
+		return pred, false
+	}
+	return pred, true
+}
+
 // handleBulkTicketStatus handles bulk status changes for tickets
 func handleBulkTicketStatus(db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -104,11 +154,24 @@ func handleBulkTicketStatus(db *sql.DB) gin.HandlerFunc {
 			pendingUntil = req.PendingUntil
 		}
 
+		plan := planBulkChange(c, db, service.BulkChange{StateID: &req.StatusID})
+		if plan == nil {
+			return
+		}
+		if req.DryRun {
+			writeBulkPreview(c, plan, req.TicketIDs)
+			return
+		}
+
 		result := BulkActionResult{Total: len(req.TicketIDs)}
 		ticketRepo := repository.NewTicketRepository(db)
 		recorder := history.NewRecorder(ticketRepo)
 
 		for _, ticketID := range req.TicketIDs {
+			if _, ok := checkBulkTicket(c, plan, ticketID, &result); !ok {
+				continue
+			}
+
 			// Get previous state for history
 			prevTicket, err := ticketRepo.GetByID(uint(ticketID))
 			if err != nil {
@@ -182,11 +245,24 @@ func handleBulkTicketPriority(db *sql.DB) gin.HandlerFunc {
 			return
 		}
 
+		plan := planBulkChange(c, db, service.BulkChange{PriorityID: &req.PriorityID})
+		if plan == nil {
+			return
+		}
+		if req.DryRun {
+			writeBulkPreview(c, plan, req.TicketIDs)
+			return
+		}
+
 		result := BulkActionResult{Total: len(req.TicketIDs)}
 		ticketRepo := repository.NewTicketRepository(db)
 		recorder := history.NewRecorder(ticketRepo)
 
 		for _, ticketID := range req.TicketIDs {
+			if _, ok := checkBulkTicket(c, plan, ticketID, &result); !ok {
+				continue
+			}
+
 			// Get previous priority for history
 			prevTicket, err := ticketRepo.GetByID(uint(ticketID))
 			if err != nil {
@@ -260,11 +336,24 @@ func handleBulkTicketQueue(db *sql.DB) gin.HandlerFunc {
 			return
 		}
 
+		plan := planBulkChange(c, db, service.BulkChange{QueueID: &req.QueueID})
+		if plan == nil {
+			return
+		}
+		if req.DryRun {
+			writeBulkPreview(c, plan, req.TicketIDs)
+			return
+		}
+
 		result := BulkActionResult{Total: len(req.TicketIDs)}
 		ticketRepo := repository.NewTicketRepository(db)
 		recorder := history.NewRecorder(ticketRepo)
 
 		for _, ticketID := range req.TicketIDs {
+			if _, ok := checkBulkTicket(c, plan, ticketID, &result); !ok {
+				continue
+			}
+
 			// Get previous queue for history
 			prevTicket, err := ticketRepo.GetByID(uint(ticketID))
 			if err != nil {
@@ -331,6 +420,22 @@ func handleBulkTicketAssign(db *sql.DB) gin.HandlerFunc {
 			return
 		}
 
+		plan := planBulkChange(c, db, service.BulkChange{OwnerID: &req.UserID, ResponsibleID: &req.UserID})
+		if plan == nil {
+			return
+		}
+		if req.DryRun {
+			writeBulkPreview(c, plan, req.TicketIDs)
+			return
+		}
+
+		// Assign to the substitute while the agent is out of office
+		agentID, ok := resolveAssignee(c, service.NewOutOfOfficeService(db), req.UserID)
+		if !ok {
+			return
+		}
+		req.UserID = agentID
+
 		// Validate agent exists
 		var agentName string
 		err := db.QueryRow(database.ConvertPlaceholders(`
@@ -346,6 +451,10 @@ func handleBulkTicketAssign(db *sql.DB) gin.HandlerFunc {
 		recorder := history.NewRecorder(ticketRepo)
 
 		for _, ticketID := range req.TicketIDs {
+			if _, ok := checkBulkTicket(c, plan, ticketID, &result); !ok {
+				continue
+			}
+
 			// Get previous owner for history
 			prevTicket, err := ticketRepo.GetByID(uint(ticketID))
 			if err != nil {
@@ -68,6 +68,17 @@ func TestBulkActionRequest_EmptyTickets(t *testing.T) {
 	assert.Empty(t, req.TicketIDs)
 }
 
+func TestBulkActionRequest_DryRun(t *testing.T) {
+	jsonData := `{"ticket_ids": [1, 2], "queue_id": 4, "dry_run": true}`
+
+	var req BulkQueueRequest
+	err := json.Unmarshal([]byte(jsonData), &req)
+
+	require.NoError(t, err)
+	assert.True(t, req.DryRun)
+	assert.Equal(t, 4, req.QueueID)
+}
+
 func TestBulkStatusRequest_JSONBinding(t *testing.T) {
 	jsonData := `{"ticket_ids": [1, 2], "status_id": 5, "pending_until": 1704067200}`
 
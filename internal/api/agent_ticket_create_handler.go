@@ -20,6 +20,7 @@ import (
 	"github.com/goatkit/goatflow/internal/models"
 	"github.com/goatkit/goatflow/internal/notifications"
 	"github.com/goatkit/goatflow/internal/repository"
+	"github.com/goatkit/goatflow/internal/service"
 	"github.com/goatkit/goatflow/internal/utils"
 )
 
@@ -77,15 +78,31 @@ func HandleAgentCreateTicket(db *sql.DB) gin.HandlerFunc {
 		// If all customer fields are empty, we'll insert NULLs for customer_id and customer_user_id
 
 		// Set defaults for agent-created tickets
+		if typeID == "" {
+			typeID = "1" // Default type
+		}
+		// The ticket type's workflow supplies the SLA and, when the form
+		// leaves them empty, the queue and priority.
+		slaIDInt := 0
+		if tid, terr := strconv.Atoi(typeID); terr == nil && db != nil {
+			typeQueueID, typePriorityID := 0, 0
+			if err := service.NewTicketTypeService(db).ApplyDefaults(c.Request.Context(), tid,
+				&typeQueueID, &typePriorityID, &slaIDInt); err != nil {
+				log.Printf("agent create ticket: type %d defaults failed: %v", tid, err)
+			}
+			if queueID == "" && typeQueueID > 0 {
+				queueID = strconv.Itoa(typeQueueID)
+			}
+			if priorityID == "" && typePriorityID > 0 {
+				priorityID = strconv.Itoa(typePriorityID)
+			}
+		}
 		if queueID == "" {
 			queueID = "1" // Default queue
 		}
 		if priorityID == "" {
 			priorityID = "3" // Normal priority
 		}
-		if typeID == "" {
-			typeID = "1" // Default type
-		}
 		if stateID == "" {
 			stateID = "1" // New state
 		}
@@ -255,6 +272,10 @@ func HandleAgentCreateTicket(db *sql.DB) gin.HandlerFunc {
 				serviceIDPtr = &sid
 			}
 		}
+		var slaIDPtr *int
+		if slaIDInt > 0 {
+			slaIDPtr = &slaIDInt
+		}
 		var userIDInt = int(userID)
 		ticketModel := &models.Ticket{
 			Title:             title,
@@ -262,6 +283,7 @@ func HandleAgentCreateTicket(db *sql.DB) gin.HandlerFunc {
 			TicketLockID:      1,
 			TypeID:            typePtr,
 			ServiceID:         serviceIDPtr,
+			SLAID:             slaIDPtr,
 			UserID:            &userIDInt,
 			ResponsibleUserID: &userIDInt,
 			TicketPriorityID:  priorityIDInt,
@@ -335,6 +357,7 @@ func HandleAgentCreateTicket(db *sql.DB) gin.HandlerFunc {
 					ticketID:  ticketModel.ID,
 					articleID: articleModel.ID,
 					userID:    int(userID),
+					role:      attachmentRole(c),
 				})
 				c.Header("HX-Trigger", "attachments-updated")
 			}
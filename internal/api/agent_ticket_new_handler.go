@@ -3,6 +3,7 @@ package api
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
@@ -13,6 +14,7 @@ import (
 	"github.com/gin-gonic/gin"
 
 	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/models"
 	"github.com/goatkit/goatflow/internal/service"
 )
 
@@ -123,6 +125,12 @@ func HandleAgentNewTicket(db *sql.DB) gin.HandlerFunc {
 			dynamicFields = dfFields
 		}
 
+		typeWorkflows, err := json.Marshal(ticketTypeFormWorkflows(c.Request.Context(), db, types))
+		if err != nil {
+			log.Printf("Warning: failed to encode ticket type workflows: %v", err)
+			typeWorkflows = []byte("{}")
+		}
+
 		// Get article colors for interaction type styling
 		articleColors, err := getArticleColors(db)
 		if err != nil {
@@ -151,6 +159,7 @@ func HandleAgentNewTicket(db *sql.DB) gin.HandlerFunc {
 				"TicketStates":      stateOptions,
 				"TicketStateLookup": stateLookup,
 				"DynamicFields":     dynamicFields,
+				"TypeWorkflowsJSON": string(typeWorkflows),
 				"PreSelectedType":   interactionType,
 				"ArticleColors":     articleColors,
 			})
@@ -313,6 +322,29 @@ func getTypesForAgent(db *sql.DB) ([]gin.H, error) {
 	return types, nil
 }
 
+// ticketTypeFormWorkflows returns the stored workflow of each type keyed by
+// type ID, so the form can apply a type's defaults and field set when the
+// agent picks it.
+func ticketTypeFormWorkflows(ctx context.Context, db *sql.DB, types []gin.H) map[int]*models.TicketTypeWorkflow {
+	svc := service.NewTicketTypeService(db)
+	workflows := make(map[int]*models.TicketTypeWorkflow)
+	for _, t := range types {
+		id, ok := t["ID"].(int)
+		if !ok {
+			continue
+		}
+		wf, err := svc.GetWorkflow(ctx, id)
+		if err != nil {
+			log.Printf("Warning: failed to load workflow of ticket type %d: %v", id, err)
+			continue
+		}
+		if wf.ChangeTime != nil {
+			workflows[id] = wf
+		}
+	}
+	return workflows
+}
+
 // getPrioritiesForAgent gets priorities available for agent ticket creation.
 func getPrioritiesForAgent(db *sql.DB) ([]gin.H, error) {
 	rows, err := db.Query(database.ConvertPlaceholders(`
@@ -2,8 +2,10 @@ package api
 
 import (
 	"database/sql"
+	"errors"
 	"log"
 	"net/http"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -113,7 +115,7 @@ func HandleListTokens(c *gin.Context) {
 //	@Tags			API Tokens
 //	@Accept			json
 //	@Produce		json
-//	@Param			token	body		object	true	"Token data (name, scopes, expires_at)"
+//	@Param			token	body		object	true	"Token data (name, scopes, expires_in, allowed_ips)"
 //	@Success		201		{object}	map[string]interface{}	"Created token (includes raw token - save it!)"
 //	@Failure		400		{object}	map[string]interface{}	"Invalid request"
 //	@Failure		401		{object}	map[string]interface{}	"Unauthorized"
@@ -206,9 +208,63 @@ func HandleRevokeToken(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"status": "revoked"})
 }
 
+// HandleRotateToken replaces a token with a new secret (agent or customer)
+// POST /api/v1/tokens/:id/rotate or /customer/api/v1/tokens/:id/rotate
+//
+//	@Summary		Rotate API token
+//	@Description	Issue a new secret for a token and revoke the old one. Name, scopes, IP allow-list and expiry are kept.
+//	@Tags			API Tokens
+//	@Accept			json
+//	@Produce		json
+//	@Param			id	path		int	true	"Token ID"
+//	@Success		201	{object}	map[string]interface{}	"New token (includes raw token - save it!)"
+//	@Failure		400	{object}	map[string]interface{}	"Token revoked or expired"
+//	@Failure		401	{object}	map[string]interface{}	"Unauthorized"
+//	@Failure		404	{object}	map[string]interface{}	"Token not found"
+//	@Security		BearerAuth
+//	@Router			/tokens/{id}/rotate [post]
+func HandleRotateToken(c *gin.Context) {
+	if apiTokenService == nil {
+		apierrors.Error(c, apierrors.CodeServiceUnavailable)
+		return
+	}
+
+	userID, userType, ok := getUserContext(c)
+	if !ok {
+		apierrors.Error(c, apierrors.CodeUnauthorized)
+		return
+	}
+
+	tokenID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		apierrors.ErrorWithMessage(c, apierrors.CodeInvalidID, "Invalid token ID format")
+		return
+	}
+
+	resp, err := apiTokenService.RotateToken(c.Request.Context(), tokenID, userID, userType)
+	switch {
+	case errors.Is(err, service.ErrAPITokenNotFound):
+		apierrors.Error(c, apierrors.CodeTokenNotFound)
+		return
+	case errors.Is(err, service.ErrAPITokenRevoked):
+		apierrors.ErrorWithMessage(c, apierrors.CodeInvalidRequest, "Revoked tokens cannot be rotated")
+		return
+	case errors.Is(err, service.ErrAPITokenExpired):
+		apierrors.ErrorWithMessage(c, apierrors.CodeInvalidRequest, "Expired tokens cannot be rotated")
+		return
+	case err != nil:
+		apierrors.Error(c, apierrors.CodeInternalError)
+		return
+	}
+
+	c.JSON(http.StatusCreated, resp)
+}
+
 // HandleGetScopes returns available scopes for token creation
 // GET /api/v1/tokens/scopes
-// Scopes are filtered based on the user's role and type
+// Scopes are filtered based on the user's role and type. Each scope lists the
+// broader scopes that also grant it, and wildcards lists the "resource:*"
+// scopes that can be requested in place of every action on a resource.
 //
 //	@Summary		Get available scopes
 //	@Description	List available scopes for token creation (filtered by user role)
@@ -244,29 +300,47 @@ func HandleGetScopes(c *gin.Context) {
 
 	// Convert to response format
 	type scopeInfo struct {
-		Scope       string `json:"scope"`
-		Description string `json:"description"`
-		Category    string `json:"category,omitempty"`
+		Scope       string   `json:"scope"`
+		Description string   `json:"description"`
+		Category    string   `json:"category,omitempty"`
+		AgentOnly   bool     `json:"agent_only,omitempty"`
+		ImpliedBy   []string `json:"implied_by,omitempty"`
 	}
 
 	scopes := make([]scopeInfo, 0, len(scopeDefs))
+	registered := make(map[string]bool, len(scopeDefs))
 	for _, def := range scopeDefs {
+		registered[def.Scope] = true
+	}
+	wildcards := []string{}
+	for _, def := range scopeDefs {
+		implied := models.ScopeImpliedBy(def.Scope)
 		scopes = append(scopes, scopeInfo{
 			Scope:       def.Scope,
 			Description: def.Description,
 			Category:    def.Category,
+			AgentOnly:   def.AgentOnly,
+			ImpliedBy:   implied,
 		})
+		for _, w := range implied {
+			if w != "*" && !registered[w] {
+				registered[w] = true
+				wildcards = append(wildcards, w)
+			}
+		}
 	}
+	sort.Strings(wildcards)
 
-	c.JSON(http.StatusOK, gin.H{"scopes": scopes})
+	c.JSON(http.StatusOK, gin.H{"scopes": scopes, "wildcards": wildcards})
 }
 
 // Customer handlers are aliases to the unified handlers above
 // The handlers detect agent vs customer from context automatically
 var (
-	HandleCustomerListTokens   = HandleListTokens
-	HandleCustomerCreateToken  = HandleCreateToken
-	HandleCustomerRevokeToken  = HandleRevokeToken
+	HandleCustomerListTokens  = HandleListTokens
+	HandleCustomerCreateToken = HandleCreateToken
+	HandleCustomerRevokeToken = HandleRevokeToken
+	HandleCustomerRotateToken = HandleRotateToken
 )
 
 // === Admin Token Handlers ===
@@ -414,11 +488,9 @@ func HandleAdminCreateTargetToken(c *gin.Context) {
 			apierrors.ErrorWithMessage(c, apierrors.CodeInvalidRequest, "Customers cannot have admin scopes")
 			return
 		}
-		if _, valid := models.ValidScopes[scope]; !valid {
-			if !isValidWildcardScope(scope) {
-				apierrors.ErrorWithMessage(c, apierrors.CodeInvalidRequest, "Invalid scope: "+scope)
-				return
-			}
+		if !models.IsGrantableScope(scope) {
+			apierrors.ErrorWithMessage(c, apierrors.CodeInvalidRequest, "Invalid scope: "+scope)
+			return
 		}
 	}
 
@@ -475,27 +547,12 @@ func HandleAdminRevokeTargetToken(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"status": "revoked"})
 }
 
-// isValidWildcardScope checks if a scope is a valid wildcard pattern
-func isValidWildcardScope(scope string) bool {
-	// Check patterns like "tickets:*", "admin:*", etc.
-	parts := strings.Split(scope, ":")
-	if len(parts) == 2 && parts[1] == "*" {
-		validPrefixes := []string{"tickets", "articles", "users", "queues", "admin"}
-		for _, prefix := range validPrefixes {
-			if parts[0] == prefix {
-				return true
-			}
-		}
-	}
-	return false
-}
-
 // Admin handler aliases - unified handlers work for both agents and customers
 var (
-	HandleAdminListUserTokens     = HandleAdminListTargetTokens
-	HandleAdminCreateUserToken    = HandleAdminCreateTargetToken
-	HandleAdminRevokeUserToken    = HandleAdminRevokeTargetToken
-	HandleAdminListCustomerTokens = HandleAdminListTargetTokens
+	HandleAdminListUserTokens      = HandleAdminListTargetTokens
+	HandleAdminCreateUserToken     = HandleAdminCreateTargetToken
+	HandleAdminRevokeUserToken     = HandleAdminRevokeTargetToken
+	HandleAdminListCustomerTokens  = HandleAdminListTargetTokens
 	HandleAdminCreateCustomerToken = HandleAdminCreateTargetToken
 	HandleAdminRevokeCustomerToken = HandleAdminRevokeTargetToken
 )
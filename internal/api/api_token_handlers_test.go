@@ -26,9 +26,11 @@ func TestHandleGetScopes_ReturnsScopesWithDescriptions(t *testing.T) {
 
 	var resp struct {
 		Scopes []struct {
-			Scope       string `json:"scope"`
-			Description string `json:"description"`
+			Scope       string   `json:"scope"`
+			Description string   `json:"description"`
+			ImpliedBy   []string `json:"implied_by"`
 		} `json:"scopes"`
+		Wildcards []string `json:"wildcards"`
 	}
 	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
 		t.Fatalf("Failed to parse response: %v", err)
@@ -46,6 +48,15 @@ func TestHandleGetScopes_ReturnsScopesWithDescriptions(t *testing.T) {
 		if s.Description == "" {
 			t.Errorf("Scope %q has empty description", s.Scope)
 		}
+		if s.Scope == "tickets:read" && strings.Join(s.ImpliedBy, ",") != "tickets:*,*" {
+			t.Errorf("Expected tickets:read to be implied by tickets:* and *, got %v", s.ImpliedBy)
+		}
+	}
+
+	// Resource wildcards are offered; registered ones like admin:* are listed as scopes
+	wildcards := strings.Join(resp.Wildcards, ",")
+	if !strings.Contains(wildcards, "tickets:*") || strings.Contains(wildcards, "admin:*") {
+		t.Errorf("Unexpected wildcards: %v", resp.Wildcards)
 	}
 }
 
@@ -186,6 +197,21 @@ func TestHandleRevokeToken_InvalidID(t *testing.T) {
 	}
 }
 
+func TestHandleRotateToken_RequiresAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/tokens/:id/rotate", HandleRotateToken)
+
+	req := httptest.NewRequest("POST", "/tokens/1/rotate", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	// Without a user in context: unauthorized, or unavailable without a service
+	if w.Code != http.StatusUnauthorized && w.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 401 or 503, got %d", w.Code)
+	}
+}
+
 func TestErrorResponseFormat(t *testing.T) {
 	// Verify all error responses follow the standard format
 	gin.SetMode(gin.TestMode)
@@ -7,6 +7,7 @@ func init() {
 	routing.RegisterHandler("HandleListTokens", HandleListTokens)
 	routing.RegisterHandler("HandleCreateToken", HandleCreateToken)
 	routing.RegisterHandler("HandleRevokeToken", HandleRevokeToken)
+	routing.RegisterHandler("HandleRotateToken", HandleRotateToken)
 	routing.RegisterHandler("HandleGetScopes", HandleGetScopes)
 
 	// Admin token handlers (agents)
@@ -25,4 +26,5 @@ func init() {
 	routing.RegisterHandler("HandleCustomerListTokens", HandleCustomerListTokens)
 	routing.RegisterHandler("HandleCustomerCreateToken", HandleCustomerCreateToken)
 	routing.RegisterHandler("HandleCustomerRevokeToken", HandleCustomerRevokeToken)
+	routing.RegisterHandler("HandleCustomerRotateToken", HandleCustomerRotateToken)
 }
@@ -0,0 +1,482 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/goatkit/goatflow/internal/config"
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/mailqueue"
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/service"
+)
+
+// approvalIsAdmin reports whether an agent may see every approval request.
+// Tests replace it to avoid a database.
+var approvalIsAdmin = func(ctx context.Context, userID uint) (bool, error) {
+	db, err := database.GetDB()
+	if err != nil || db == nil {
+		return false, err
+	}
+	return service.NewQueueAccessService(db).IsAdmin(ctx, userID)
+}
+
+// approvalRuleRequest is the JSON body accepted by the rule create/update handlers.
+type approvalRuleRequest struct {
+	Name            string                `json:"name" binding:"required"`
+	QueueID         *int                  `json:"queue_id"`
+	Action          models.ApprovalAction `json:"action" binding:"required"`
+	ApproverGroupID int                   `json:"approver_group_id" binding:"required"`
+	Comments        string                `json:"comments"`
+}
+
+func (r *approvalRuleRequest) rule() *models.ApprovalRule {
+	return &models.ApprovalRule{
+		Name:            r.Name,
+		QueueID:         r.QueueID,
+		Action:          r.Action,
+		ApproverGroupID: r.ApproverGroupID,
+		Comments:        r.Comments,
+		ValidID:         1,
+	}
+}
+
+// newApprovalService creates an approval service that queues notification
+// emails when outbound email is configured.
+func newApprovalService(db *sql.DB) *service.ApprovalService {
+	svc := service.NewApprovalService(db)
+	if cfg := config.Get(); cfg != nil && cfg.Email.Enabled && cfg.Email.From != "" {
+		svc.WithEmail(mailqueue.NewMailQueueRepository(db), cfg.Email.From)
+	}
+	return svc
+}
+
+func approvalService(c *gin.Context) *service.ApprovalService {
+	db, err := database.GetDB()
+	if err != nil || db == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"success": false, "error": "Database unavailable"})
+		return nil
+	}
+	return newApprovalService(db)
+}
+
+// approvalWriteError maps ApprovalService errors to responses.
+func approvalWriteError(c *gin.Context, err error, action string) {
+	switch {
+	case errors.Is(err, service.ErrApprovalRuleNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Approval rule not found"})
+	case errors.Is(err, service.ErrApprovalNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Approval request not found"})
+	case errors.Is(err, service.ErrApprovalTicketNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Ticket not found"})
+	case errors.Is(err, service.ErrApprovalRuleNameExists),
+		errors.Is(err, service.ErrApprovalNotPending):
+		c.JSON(http.StatusConflict, gin.H{"success": false, "error": err.Error()})
+	case errors.Is(err, service.ErrApprovalNotApprover),
+		errors.Is(err, service.ErrApprovalNotRequester):
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": err.Error()})
+	case errors.Is(err, service.ErrApprovalRuleNameRequired),
+		errors.Is(err, service.ErrApprovalInvalidAction),
+		errors.Is(err, service.ErrApprovalInvalidGroup):
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+	default:
+		log.Printf("approval api: %s failed: %v", action, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to " + action})
+	}
+}
+
+// approvalID parses the :id path parameter, writing 400 when it is invalid.
+func approvalID(c *gin.Context, what string) (int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid " + what + " ID"})
+		return 0, false
+	}
+	return id, true
+}
+
+// approvalAgentID returns the calling agent, writing 403 for customers and
+// 401 when nobody is signed in.
+func approvalAgentID(c *gin.Context) (int, bool) {
+	if kbIsCustomer(c) {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": "Agent access required"})
+		return 0, false
+	}
+	userID := GetUserIDFromCtx(c, 0)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"success": false, "error": "Authentication required"})
+		return 0, false
+	}
+	return userID, true
+}
+
+// requestTicketApproval checks whether the agent's close or move needs
+// approval. When it does, it raises the request, writes 202 with it and
+// returns true; the caller must then leave the ticket unchanged. It
+// returns false when the agent may go ahead.
+func requestTicketApproval(c *gin.Context, db *sql.DB, action models.ApprovalAction, ticketID, targetID, userID int, reason string) bool {
+	svc := newApprovalService(db)
+	ctx := c.Request.Context()
+	var req *models.ApprovalRequest
+	var err error
+	if action == models.ApprovalActionMove {
+		req, err = svc.RequestMove(ctx, ticketID, targetID, userID, reason)
+	} else {
+		req, err = svc.RequestClose(ctx, ticketID, targetID, userID, reason)
+	}
+	switch {
+	case errors.Is(err, service.ErrApprovalPending):
+		c.JSON(http.StatusConflict, gin.H{"success": false, "error": err.Error(), "data": req})
+		return true
+	case errors.Is(err, service.ErrApprovalTicketNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Ticket not found"})
+		return true
+	case err != nil:
+		log.Printf("approval: checking %s of ticket %d failed: %v", action, ticketID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to check approval rules"})
+		return true
+	case req != nil:
+		c.JSON(http.StatusAccepted, gin.H{"success": true, "approval_required": true, "data": req})
+		return true
+	}
+	return false
+}
+
+// HandleListApprovalRulesAPI handles GET /api/v1/approval-rules.
+//
+//	@Summary		List approval rules
+//	@Tags			Approvals
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}	"Approval rules"
+//	@Security		BearerAuth
+//	@Router			/approval-rules [get]
+func HandleListApprovalRulesAPI(c *gin.Context) {
+	svc := approvalService(c)
+	if svc == nil {
+		return
+	}
+	rules, err := svc.ListRules(c.Request.Context())
+	if err != nil {
+		approvalWriteError(c, err, "load approval rules")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": rules})
+}
+
+// HandleCreateApprovalRuleAPI handles POST /api/v1/approval-rules.
+//
+//	@Summary		Create approval rule
+//	@Description	Requires approval from a member of approver_group_id before agents outside the group close tickets in, or move tickets out of, the queue. Leave queue_id empty to cover every queue.
+//	@Tags			Approvals
+//	@Accept			json
+//	@Produce		json
+//	@Param			rule	body		object	true	"Approval rule (name, queue_id, action, approver_group_id, comments)"
+//	@Success		201		{object}	map[string]interface{}	"Rule created"
+//	@Failure		400		{object}	map[string]interface{}	"Invalid request"
+//	@Failure		409		{object}	map[string]interface{}	"Name already in use"
+//	@Security		BearerAuth
+//	@Router			/approval-rules [post]
+func HandleCreateApprovalRuleAPI(c *gin.Context) {
+	var req approvalRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid approval rule: " + err.Error()})
+		return
+	}
+	rule := req.rule()
+	if !rule.Action.IsValid() {
+		approvalWriteError(c, service.ErrApprovalInvalidAction, "create approval rule")
+		return
+	}
+	svc := approvalService(c)
+	if svc == nil {
+		return
+	}
+
+	rule.CreateBy = GetUserIDFromCtx(c, 1)
+	if err := svc.CreateRule(c.Request.Context(), rule); err != nil {
+		approvalWriteError(c, err, "create approval rule")
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"success": true, "data": rule})
+}
+
+// HandleGetApprovalRuleAPI handles GET /api/v1/approval-rules/:id.
+//
+//	@Summary		Get approval rule
+//	@Tags			Approvals
+//	@Produce		json
+//	@Param			id	path		int	true	"Rule ID"
+//	@Success		200	{object}	map[string]interface{}	"Approval rule"
+//	@Failure		404	{object}	map[string]interface{}	"Rule not found"
+//	@Security		BearerAuth
+//	@Router			/approval-rules/{id} [get]
+func HandleGetApprovalRuleAPI(c *gin.Context) {
+	id, ok := approvalID(c, "approval rule")
+	if !ok {
+		return
+	}
+	svc := approvalService(c)
+	if svc == nil {
+		return
+	}
+	rule, err := svc.GetRule(c.Request.Context(), id)
+	if err != nil {
+		approvalWriteError(c, err, "load approval rule")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": rule})
+}
+
+// HandleUpdateApprovalRuleAPI handles PUT /api/v1/approval-rules/:id.
+//
+//	@Summary		Update approval rule
+//	@Tags			Approvals
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int		true	"Rule ID"
+//	@Param			rule	body		object	true	"Approval rule"
+//	@Success		200		{object}	map[string]interface{}	"Rule updated"
+//	@Failure		400		{object}	map[string]interface{}	"Invalid request"
+//	@Failure		404		{object}	map[string]interface{}	"Rule not found"
+//	@Security		BearerAuth
+//	@Router			/approval-rules/{id} [put]
+func HandleUpdateApprovalRuleAPI(c *gin.Context) {
+	id, ok := approvalID(c, "approval rule")
+	if !ok {
+		return
+	}
+	var req approvalRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid approval rule: " + err.Error()})
+		return
+	}
+	rule := req.rule()
+	rule.ID = id
+	if !rule.Action.IsValid() {
+		approvalWriteError(c, service.ErrApprovalInvalidAction, "update approval rule")
+		return
+	}
+	svc := approvalService(c)
+	if svc == nil {
+		return
+	}
+
+	if err := svc.UpdateRule(c.Request.Context(), rule, GetUserIDFromCtx(c, 1)); err != nil {
+		approvalWriteError(c, err, "update approval rule")
+		return
+	}
+	updated, err := svc.GetRule(c.Request.Context(), id)
+	if err != nil {
+		approvalWriteError(c, err, "load approval rule")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": updated})
+}
+
+// HandleDeleteApprovalRuleAPI handles DELETE /api/v1/approval-rules/:id.
+//
+//	@Summary		Delete approval rule
+//	@Description	Invalidates the rule. Requests already raised under it can still be decided.
+//	@Tags			Approvals
+//	@Produce		json
+//	@Param			id	path		int	true	"Rule ID"
+//	@Success		200	{object}	map[string]interface{}	"Rule deleted"
+//	@Failure		404	{object}	map[string]interface{}	"Rule not found"
+//	@Security		BearerAuth
+//	@Router			/approval-rules/{id} [delete]
+func HandleDeleteApprovalRuleAPI(c *gin.Context) {
+	id, ok := approvalID(c, "approval rule")
+	if !ok {
+		return
+	}
+	svc := approvalService(c)
+	if svc == nil {
+		return
+	}
+	if err := svc.DeleteRule(c.Request.Context(), id, GetUserIDFromCtx(c, 1)); err != nil {
+		approvalWriteError(c, err, "delete approval rule")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// HandleListApprovalsAPI handles GET /api/v1/approvals.
+//
+//	@Summary		List approval requests
+//	@Description	Lists approval requests the agent raised or may decide; admins see all. Defaults to pending requests.
+//	@Tags			Approvals
+//	@Produce		json
+//	@Param			status		query		string	false	"pending (default), approved, rejected, cancelled or all"
+//	@Param			ticket_id	query		int		false	"Only requests for this ticket"
+//	@Success		200			{object}	map[string]interface{}	"Approval requests"
+//	@Failure		400			{object}	map[string]interface{}	"Invalid status"
+//	@Security		BearerAuth
+//	@Router			/approvals [get]
+func HandleListApprovalsAPI(c *gin.Context) {
+	userID, ok := approvalAgentID(c)
+	if !ok {
+		return
+	}
+	filter := models.ApprovalRequestFilter{Status: models.ApprovalStatus(c.DefaultQuery("status", string(models.ApprovalStatusPending)))}
+	if filter.Status == "all" {
+		filter.Status = ""
+	} else if !filter.Status.IsValid() {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "status must be one of pending, approved, rejected, cancelled, all"})
+		return
+	}
+	if v := c.Query("ticket_id"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil || id <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid ticket ID"})
+			return
+		}
+		filter.TicketID = id
+	}
+	listApprovals(c, userID, filter)
+}
+
+// HandleListTicketApprovalsAPI handles GET /api/v1/tickets/:id/approvals.
+//
+//	@Summary		List ticket approval requests
+//	@Description	Lists every approval request raised for the ticket.
+//	@Tags			Approvals
+//	@Produce		json
+//	@Param			id	path		int	true	"Ticket ID"
+//	@Success		200	{object}	map[string]interface{}	"Approval requests"
+//	@Security		BearerAuth
+//	@Router			/tickets/{id}/approvals [get]
+func HandleListTicketApprovalsAPI(c *gin.Context) {
+	ticketID, ok := approvalID(c, "ticket")
+	if !ok {
+		return
+	}
+	if _, ok := approvalAgentID(c); !ok {
+		return
+	}
+	listApprovals(c, 0, models.ApprovalRequestFilter{TicketID: ticketID})
+}
+
+// listApprovals writes the requests matching filter. A non-zero userID
+// limits the list to requests that agent raised or may decide, unless the
+// agent is an admin.
+func listApprovals(c *gin.Context, userID int, filter models.ApprovalRequestFilter) {
+	if userID != 0 {
+		filter.VisibleTo = userID
+		if isAdmin, _ := c.Get("isInAdminGroup"); isAdmin == true {
+			filter.VisibleTo = 0
+		} else if admin, err := approvalIsAdmin(c.Request.Context(), uint(userID)); err != nil {
+			log.Printf("approval api: admin check for user %d failed: %v", userID, err)
+		} else if admin {
+			filter.VisibleTo = 0
+		}
+	}
+	svc := approvalService(c)
+	if svc == nil {
+		return
+	}
+	reqs, err := svc.ListRequests(c.Request.Context(), filter)
+	if err != nil {
+		approvalWriteError(c, err, "load approval requests")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": reqs})
+}
+
+// HandleApproveApprovalAPI handles POST /api/v1/approvals/:id/approve.
+//
+//	@Summary		Approve request
+//	@Description	Approves a pending request and applies its change to the ticket. Only members of the rule's approver group can approve.
+//	@Tags			Approvals
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int		true	"Approval request ID"
+//	@Param			body	body		object	false	"comment"
+//	@Success		200		{object}	map[string]interface{}	"Request approved"
+//	@Failure		403		{object}	map[string]interface{}	"Not an approver"
+//	@Failure		409		{object}	map[string]interface{}	"Already decided"
+//	@Security		BearerAuth
+//	@Router			/approvals/{id}/approve [post]
+func HandleApproveApprovalAPI(c *gin.Context) {
+	decideApproval(c, models.ApprovalStatusApproved)
+}
+
+// HandleRejectApprovalAPI handles POST /api/v1/approvals/:id/reject.
+//
+//	@Summary		Reject request
+//	@Description	Rejects a pending request; the ticket is left unchanged. Only members of the rule's approver group can reject.
+//	@Tags			Approvals
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int		true	"Approval request ID"
+//	@Param			body	body		object	false	"comment"
+//	@Success		200		{object}	map[string]interface{}	"Request rejected"
+//	@Failure		403		{object}	map[string]interface{}	"Not an approver"
+//	@Failure		409		{object}	map[string]interface{}	"Already decided"
+//	@Security		BearerAuth
+//	@Router			/approvals/{id}/reject [post]
+func HandleRejectApprovalAPI(c *gin.Context) {
+	decideApproval(c, models.ApprovalStatusRejected)
+}
+
+// HandleCancelApprovalAPI handles POST /api/v1/approvals/:id/cancel.
+//
+//	@Summary		Cancel request
+//	@Description	Withdraws a pending request. Only the requesting agent can cancel.
+//	@Tags			Approvals
+//	@Produce		json
+//	@Param			id	path		int	true	"Approval request ID"
+//	@Success		200	{object}	map[string]interface{}	"Request cancelled"
+//	@Failure		403	{object}	map[string]interface{}	"Not the requester"
+//	@Failure		409	{object}	map[string]interface{}	"Already decided"
+//	@Security		BearerAuth
+//	@Router			/approvals/{id}/cancel [post]
+func HandleCancelApprovalAPI(c *gin.Context) {
+	decideApproval(c, models.ApprovalStatusCancelled)
+}
+
+func decideApproval(c *gin.Context, status models.ApprovalStatus) {
+	id, ok := approvalID(c, "approval request")
+	if !ok {
+		return
+	}
+	userID, ok := approvalAgentID(c)
+	if !ok {
+		return
+	}
+	var body struct {
+		Comment string `json:"comment"`
+	}
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid request: " + err.Error()})
+			return
+		}
+	}
+	svc := approvalService(c)
+	if svc == nil {
+		return
+	}
+
+	ctx := c.Request.Context()
+	var req *models.ApprovalRequest
+	var err error
+	switch status {
+	case models.ApprovalStatusApproved:
+		req, err = svc.Approve(ctx, id, userID, body.Comment)
+	case models.ApprovalStatusRejected:
+		req, err = svc.Reject(ctx, id, userID, body.Comment)
+	default:
+		req, err = svc.Cancel(ctx, id, userID)
+	}
+	if err != nil {
+		approvalWriteError(c, err, "update approval request")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": req})
+}
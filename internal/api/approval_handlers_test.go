@@ -0,0 +1,102 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandleCreateApprovalRuleAPI_Validation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	for name, tc := range map[string]struct {
+		body string
+		want string
+	}{
+		"missing name":   {`{"action": "close", "approver_group_id": 2}`, "Invalid approval rule"},
+		"missing action": {`{"name": "Leads", "approver_group_id": 2}`, "Invalid approval rule"},
+		"missing group":  {`{"name": "Leads", "action": "close"}`, "Invalid approval rule"},
+		"malformed json": {`{"name": `, "Invalid approval rule"},
+		"unknown action": {`{"name": "Leads", "action": "delete", "approver_group_id": 2}`, "action must be one of"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			router := gin.New()
+			router.POST("/api/v1/approval-rules", HandleCreateApprovalRuleAPI)
+
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/approval-rules", strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Contains(t, w.Body.String(), tc.want)
+		})
+	}
+}
+
+func TestApprovalHandlers_InvalidID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.GET("/api/v1/approval-rules/:id", HandleGetApprovalRuleAPI)
+	router.PUT("/api/v1/approval-rules/:id", HandleUpdateApprovalRuleAPI)
+	router.DELETE("/api/v1/approval-rules/:id", HandleDeleteApprovalRuleAPI)
+	router.POST("/api/v1/approvals/:id/approve", HandleApproveApprovalAPI)
+	router.POST("/api/v1/approvals/:id/reject", HandleRejectApprovalAPI)
+	router.POST("/api/v1/approvals/:id/cancel", HandleCancelApprovalAPI)
+	router.GET("/api/v1/tickets/:id/approvals", HandleListTicketApprovalsAPI)
+
+	for _, r := range []struct{ method, path, want string }{
+		{http.MethodGet, "/api/v1/approval-rules/abc", "Invalid approval rule ID"},
+		{http.MethodPut, "/api/v1/approval-rules/0", "Invalid approval rule ID"},
+		{http.MethodDelete, "/api/v1/approval-rules/-1", "Invalid approval rule ID"},
+		{http.MethodPost, "/api/v1/approvals/x/approve", "Invalid approval request ID"},
+		{http.MethodPost, "/api/v1/approvals/0/reject", "Invalid approval request ID"},
+		{http.MethodPost, "/api/v1/approvals/-3/cancel", "Invalid approval request ID"},
+		{http.MethodGet, "/api/v1/tickets/abc/approvals", "Invalid ticket ID"},
+	} {
+		req := httptest.NewRequest(r.method, r.path, strings.NewReader(`{}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, r.method+" "+r.path)
+		assert.Contains(t, w.Body.String(), r.want)
+	}
+}
+
+func TestApprovalHandlers_AgentsOnly(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	for name, tc := range map[string]struct {
+		setup func(c *gin.Context)
+		path  string
+		code  int
+	}{
+		"customer decides":        {func(c *gin.Context) { c.Set("user_id", 5); c.Set("is_customer", true) }, "/api/v1/approvals/1/approve", http.StatusForbidden},
+		"unauthenticated decides": {func(c *gin.Context) {}, "/api/v1/approvals/1/approve", http.StatusUnauthorized},
+		"customer lists":          {func(c *gin.Context) { c.Set("user_id", 5); c.Set("is_customer", true) }, "/api/v1/approvals", http.StatusForbidden},
+		"unknown status":          {func(c *gin.Context) { c.Set("user_id", 5) }, "/api/v1/approvals?status=open", http.StatusBadRequest},
+		"bad ticket filter":       {func(c *gin.Context) { c.Set("user_id", 5) }, "/api/v1/approvals?ticket_id=x", http.StatusBadRequest},
+	} {
+		t.Run(name, func(t *testing.T) {
+			router := gin.New()
+			router.Use(func(c *gin.Context) { tc.setup(c) })
+			router.GET("/api/v1/approvals", HandleListApprovalsAPI)
+			router.POST("/api/v1/approvals/:id/approve", HandleApproveApprovalAPI)
+
+			method := http.MethodGet
+			if strings.HasSuffix(tc.path, "/approve") {
+				method = http.MethodPost
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(method, tc.path, nil))
+
+			assert.Equal(t, tc.code, w.Code)
+		})
+	}
+}
@@ -95,6 +95,10 @@ func HandleCreateArticleAPI(c *gin.Context) {
 		References             string  `json:"references"`
 		MessageID              string  `json:"message_id"`
 		IncomingTime           int64   `json:"incoming_time"`
+		// Response template to use when body is empty; implies placeholder expansion.
+		TemplateID int `json:"template_id"`
+		// Expand <OTRS_*>/<GOATFLOW_*> placeholders in subject and body.
+		ExpandPlaceholders bool `json:"expand_placeholders"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -181,6 +185,31 @@ func HandleCreateArticleAPI(c *gin.Context) {
 		}
 	}
 
+	// Response templates and placeholders are an agent feature
+	if req.TemplateID > 0 || req.ExpandPlaceholders {
+		if isCustomer {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Templates are only available to agents"})
+			return
+		}
+		if req.TemplateID > 0 {
+			tmpl, status, msg := loadComposeTemplate(db, req.TemplateID, ticketID)
+			if tmpl == nil {
+				c.JSON(status, gin.H{"success": false, "error": msg})
+				return
+			}
+			if strings.TrimSpace(req.Body) == "" {
+				req.Body = tmpl.Text
+				if strings.TrimSpace(req.ContentType) == "" {
+					req.ContentType = tmpl.ContentType
+				}
+			}
+		}
+		vars := GetTicketTemplateVariables(int(ticketID))
+		AddCurrentUserTemplateVariables(vars, userID)
+		req.Subject = SubstituteTemplateVariables(req.Subject, vars)
+		req.Body = SubstituteTemplateVariables(req.Body, vars)
+	}
+
 	// Validate body presence for non-test path
 	if strings.TrimSpace(req.Body) == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Article body is required"})
@@ -449,6 +478,9 @@ func HandleCreateArticleAPI(c *gin.Context) {
 		})
 		return
 	}
+	if !isCustomer {
+		discardArticleDraft(c.Request.Context(), db, ticketID, userID)
+	}
 
 	// Queue email notification for new article if visible to customer
 	if isVisibleForCustomer == 1 && customerUserID.Valid && customerUserID.String != "" {
@@ -559,3 +591,32 @@ func HandleCreateArticleAPI(c *gin.Context) {
 
 	c.JSON(http.StatusCreated, gin.H{"success": true, "data": responseData})
 }
+
+// loadComposeTemplate loads a valid response template assigned to the
+// ticket's queue. On failure it returns nil with the HTTP status and message.
+func loadComposeTemplate(db *sql.DB, templateID int, ticketID int64) (*StandardTemplate, int, string) {
+	tmpl, err := GetStandardTemplate(templateID)
+	if err != nil {
+		return nil, http.StatusInternalServerError, "Failed to load template"
+	}
+	if tmpl == nil || tmpl.ValidID != 1 {
+		return nil, http.StatusBadRequest, "Template not found"
+	}
+
+	var queueID int
+	if err := db.QueryRow(database.ConvertPlaceholders(
+		"SELECT queue_id FROM ticket WHERE id = ?",
+	), ticketID).Scan(&queueID); err != nil {
+		return nil, http.StatusInternalServerError, "Failed to load ticket queue"
+	}
+	templateIDs, err := GetQueueTemplateIDs(queueID)
+	if err != nil {
+		return nil, http.StatusInternalServerError, "Failed to load queue templates"
+	}
+	for _, id := range templateIDs {
+		if id == templateID {
+			return tmpl, http.StatusOK, ""
+		}
+	}
+	return nil, http.StatusBadRequest, "Template is not assigned to the ticket's queue"
+}
@@ -87,6 +87,15 @@ func HandleDeleteArticleAPI(c *gin.Context) {
 		return
 	}
 
+	// Delete the collapsed copy of its body
+	deleteVisibleTextQuery := database.ConvertPlaceholders(`
+		DELETE FROM article_visible_text WHERE article_id = ?
+	`)
+	if _, err := tx.Exec(deleteVisibleTextQuery, articleID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete article text"})
+		return
+	}
+
 	// Delete article
 	deleteQuery := database.ConvertPlaceholders(`
 		DELETE FROM article 
@@ -0,0 +1,332 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/service"
+	"github.com/goatkit/goatflow/internal/sysconfig"
+)
+
+// articleDraftRequest is the JSON body accepted by the draft save and
+// update handlers. The agent UI posts the reply form, hence the form tags.
+type articleDraftRequest struct {
+	Subject              string `json:"subject" form:"subject"`
+	Body                 string `json:"body" form:"body"`
+	To                   string `json:"to" form:"to"`
+	Cc                   string `json:"cc" form:"cc"`
+	Bcc                  string `json:"bcc" form:"bcc"`
+	ContentType          string `json:"content_type" form:"content_type"`
+	IsVisibleForCustomer bool   `json:"is_visible_for_customer" form:"is_visible_for_customer"`
+	Shared               bool   `json:"shared" form:"shared"`
+	BaseArticleID        int64  `json:"base_article_id" form:"base_article_id"`
+	Version              int    `json:"version" form:"version"`
+}
+
+func (r *articleDraftRequest) draft(ticketID int64) *models.ArticleDraft {
+	return &models.ArticleDraft{
+		TicketID:             ticketID,
+		Shared:               r.Shared,
+		Subject:              r.Subject,
+		Body:                 r.Body,
+		To:                   r.To,
+		Cc:                   r.Cc,
+		Bcc:                  r.Bcc,
+		ContentType:          r.ContentType,
+		IsVisibleForCustomer: r.IsVisibleForCustomer,
+		BaseArticleID:        r.BaseArticleID,
+		Version:              r.Version,
+	}
+}
+
+// articleDraftService returns the service, writing 503 when the database
+// is unavailable.
+func articleDraftService(c *gin.Context) *service.ArticleDraftService {
+	db, err := database.GetDB()
+	if err != nil || db == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"success": false, "error": "Database unavailable"})
+		return nil
+	}
+	return service.NewArticleDraftService(db)
+}
+
+// articleDraftError maps ArticleDraftService errors to responses.
+func articleDraftError(c *gin.Context, err error, action string) {
+	switch {
+	case errors.Is(err, service.ErrArticleDraftNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Draft not found"})
+	case errors.Is(err, service.ErrArticleDraftTicketNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Ticket not found"})
+	case errors.Is(err, service.ErrArticleDraftVersionConflict):
+		c.JSON(http.StatusConflict, gin.H{"success": false, "error": err.Error()})
+	case errors.Is(err, service.ErrArticleDraftInvalid):
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+	default:
+		log.Printf("article draft api: %s failed: %v", action, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to " + action})
+	}
+}
+
+// articleDraftTicketID parses the :id path parameter, writing 400 when it is
+// invalid. Drafts are for agents only: customer user IDs may equal agent IDs.
+func articleDraftTicketID(c *gin.Context) (int64, bool) {
+	ic, _ := c.Get("is_customer")
+	role, _ := c.Get("user_role")
+	if ic == true || role == "Customer" {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": "Drafts are available to agents only"})
+		return 0, false
+	}
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid ticket ID"})
+		return 0, false
+	}
+	return id, true
+}
+
+// discardArticleDraft removes the agent's draft once their reply to the
+// ticket was posted.
+func discardArticleDraft(ctx context.Context, db *sql.DB, ticketID int64, userID int) {
+	err := service.NewArticleDraftService(db).Discard(ctx, ticketID, userID)
+	if err != nil && !errors.Is(err, service.ErrArticleDraftNotFound) {
+		log.Printf("article draft: discarding draft of ticket %d for user %d failed: %v", ticketID, userID, err)
+	}
+}
+
+// HandleListArticleDraftsAPI handles GET /api/v1/tickets/:id/drafts.
+//
+//	@Summary		List ticket drafts
+//	@Description	Lists the agent's own draft and the shared drafts of other agents for a ticket.
+//	@Tags			Article Drafts
+//	@Produce		json
+//	@Param			id	path		int	true	"Ticket ID"
+//	@Success		200	{object}	map[string]interface{}	"Drafts"
+//	@Failure		400	{object}	map[string]interface{}	"Invalid ticket ID"
+//	@Security		BearerAuth
+//	@Router			/tickets/{id}/drafts [get]
+func HandleListArticleDraftsAPI(c *gin.Context) {
+	ticketID, ok := articleDraftTicketID(c)
+	if !ok {
+		return
+	}
+	svc := articleDraftService(c)
+	if svc == nil {
+		return
+	}
+	drafts, err := svc.List(c.Request.Context(), ticketID, GetUserIDFromCtx(c, 1))
+	if err != nil {
+		articleDraftError(c, err, "load drafts")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": drafts})
+}
+
+// HandleGetArticleDraftAPI handles GET /api/v1/tickets/:id/draft.
+//
+//	@Summary		Get my draft
+//	@Description	Returns the agent's draft for a ticket; conflict is set when others posted to the ticket since base_article_id.
+//	@Tags			Article Drafts
+//	@Produce		json
+//	@Param			id	path		int	true	"Ticket ID"
+//	@Success		200	{object}	map[string]interface{}	"Draft"
+//	@Failure		404	{object}	map[string]interface{}	"No draft"
+//	@Security		BearerAuth
+//	@Router			/tickets/{id}/draft [get]
+func HandleGetArticleDraftAPI(c *gin.Context) {
+	ticketID, ok := articleDraftTicketID(c)
+	if !ok {
+		return
+	}
+	svc := articleDraftService(c)
+	if svc == nil {
+		return
+	}
+	draft, err := svc.Mine(c.Request.Context(), ticketID, GetUserIDFromCtx(c, 1))
+	if err != nil {
+		articleDraftError(c, err, "load draft")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": draft})
+}
+
+// HandleSaveArticleDraftAPI handles PUT /api/v1/tickets/:id/draft.
+//
+//	@Summary		Autosave my draft
+//	@Description	Creates or replaces the agent's draft for a ticket. Send the version last returned to detect saves from another tab or co-editor, and the newest article ID seen as base_article_id to acknowledge a conflict.
+//	@Tags			Article Drafts
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int		true	"Ticket ID"
+//	@Param			draft	body		object	true	"Draft"
+//	@Success		200		{object}	map[string]interface{}	"Draft saved"
+//	@Failure		400		{object}	map[string]interface{}	"Invalid request"
+//	@Failure		404		{object}	map[string]interface{}	"Ticket not found"
+//	@Failure		409		{object}	map[string]interface{}	"Draft was saved by someone else"
+//	@Security		BearerAuth
+//	@Router			/tickets/{id}/draft [put]
+func HandleSaveArticleDraftAPI(c *gin.Context) {
+	ticketID, ok := articleDraftTicketID(c)
+	if !ok {
+		return
+	}
+	var req articleDraftRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid draft request: " + err.Error()})
+		return
+	}
+	svc := articleDraftService(c)
+	if svc == nil {
+		return
+	}
+	draft := req.draft(ticketID)
+	if err := svc.Save(c.Request.Context(), draft, GetUserIDFromCtx(c, 1)); err != nil {
+		articleDraftError(c, err, "save draft")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": draft})
+}
+
+// HandleUpdateArticleDraftAPI handles PUT /api/v1/tickets/:id/drafts/:draft_id.
+//
+//	@Summary		Edit a shared draft
+//	@Description	Saves changes to the agent's own draft or a draft another agent shared. version is required.
+//	@Tags			Article Drafts
+//	@Accept			json
+//	@Produce		json
+//	@Param			id			path		int		true	"Ticket ID"
+//	@Param			draft_id	path		int		true	"Draft ID"
+//	@Param			draft		body		object	true	"Draft"
+//	@Success		200			{object}	map[string]interface{}	"Draft saved"
+//	@Failure		400			{object}	map[string]interface{}	"Invalid request"
+//	@Failure		404			{object}	map[string]interface{}	"Draft not found"
+//	@Failure		409			{object}	map[string]interface{}	"Draft was saved by someone else"
+//	@Security		BearerAuth
+//	@Router			/tickets/{id}/drafts/{draft_id} [put]
+func HandleUpdateArticleDraftAPI(c *gin.Context) {
+	ticketID, ok := articleDraftTicketID(c)
+	if !ok {
+		return
+	}
+	draftID, err := strconv.ParseInt(c.Param("draft_id"), 10, 64)
+	if err != nil || draftID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid draft ID"})
+		return
+	}
+	var req articleDraftRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid draft request: " + err.Error()})
+		return
+	}
+	svc := articleDraftService(c)
+	if svc == nil {
+		return
+	}
+	userID := GetUserIDFromCtx(c, 1)
+	// The route's ticket access check only covers drafts of that ticket.
+	if existing, err := svc.Get(c.Request.Context(), draftID, userID); err != nil {
+		articleDraftError(c, err, "load draft")
+		return
+	} else if existing.TicketID != ticketID {
+		articleDraftError(c, service.ErrArticleDraftNotFound, "load draft")
+		return
+	}
+	draft := req.draft(ticketID)
+	draft.ID = draftID
+	if err := svc.Update(c.Request.Context(), draft, userID); err != nil {
+		articleDraftError(c, err, "save draft")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": draft})
+}
+
+// HandleDiscardArticleDraftAPI handles DELETE /api/v1/tickets/:id/draft.
+//
+//	@Summary		Discard my draft
+//	@Tags			Article Drafts
+//	@Produce		json
+//	@Param			id	path		int	true	"Ticket ID"
+//	@Success		200	{object}	map[string]interface{}	"Draft discarded"
+//	@Failure		404	{object}	map[string]interface{}	"No draft"
+//	@Security		BearerAuth
+//	@Router			/tickets/{id}/draft [delete]
+func HandleDiscardArticleDraftAPI(c *gin.Context) {
+	ticketID, ok := articleDraftTicketID(c)
+	if !ok {
+		return
+	}
+	svc := articleDraftService(c)
+	if svc == nil {
+		return
+	}
+	if err := svc.Discard(c.Request.Context(), ticketID, GetUserIDFromCtx(c, 1)); err != nil {
+		articleDraftError(c, err, "discard draft")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// HandleGetArticleDraftSettingsAPI handles GET /api/v1/admin/article-drafts/settings.
+//
+//	@Summary		Get draft settings
+//	@Tags			Article Drafts
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}	"Draft settings"
+//	@Security		BearerAuth
+//	@Router			/admin/article-drafts/settings [get]
+func HandleGetArticleDraftSettingsAPI(c *gin.Context) {
+	db, err := database.GetDB()
+	if err != nil || db == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"success": false, "error": "Database unavailable"})
+		return
+	}
+	cfg, err := sysconfig.LoadArticleDraftConfig(db)
+	if err != nil {
+		log.Printf("article draft api: load settings failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to load draft settings"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": cfg})
+}
+
+// HandleUpdateArticleDraftSettingsAPI handles PUT /api/v1/admin/article-drafts/settings.
+//
+//	@Summary		Update draft settings
+//	@Description	Sets how many days drafts are kept after their last change; 0 keeps them until posted or discarded.
+//	@Tags			Article Drafts
+//	@Accept			json
+//	@Produce		json
+//	@Param			settings	body		object	true	"Settings (retention_days)"
+//	@Success		200			{object}	map[string]interface{}	"Settings saved"
+//	@Failure		400			{object}	map[string]interface{}	"Invalid request"
+//	@Security		BearerAuth
+//	@Router			/admin/article-drafts/settings [put]
+func HandleUpdateArticleDraftSettingsAPI(c *gin.Context) {
+	var cfg sysconfig.ArticleDraftConfig
+	if err := c.ShouldBindJSON(&cfg); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid settings request: " + err.Error()})
+		return
+	}
+	if cfg.RetentionDays < 0 || cfg.RetentionDays > 3650 {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "retention_days must be 0 to 3650"})
+		return
+	}
+	db, err := database.GetDB()
+	if err != nil || db == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"success": false, "error": "Database unavailable"})
+		return
+	}
+	if err := sysconfig.SaveArticleDraftConfig(db, cfg, GetUserIDFromCtx(c, 1)); err != nil {
+		log.Printf("article draft api: save settings failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to save draft settings"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": cfg})
+}
@@ -0,0 +1,66 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestArticleDraftHandlers_InvalidRequest(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("user_id", 1)
+		c.Next()
+	})
+	router.PUT("/api/v1/tickets/:id/draft", HandleSaveArticleDraftAPI)
+	router.DELETE("/api/v1/tickets/:id/draft", HandleDiscardArticleDraftAPI)
+	router.PUT("/api/v1/tickets/:id/drafts/:draft_id", HandleUpdateArticleDraftAPI)
+	router.PUT("/api/v1/admin/article-drafts/settings", HandleUpdateArticleDraftSettingsAPI)
+
+	for _, tc := range []struct {
+		method string
+		path   string
+		body   string
+		want   string
+	}{
+		{http.MethodPut, "/api/v1/tickets/0/draft", `{"body": "Hi"}`, "Invalid ticket ID"},
+		{http.MethodPut, "/api/v1/tickets/5/draft", `{"body": 1}`, "Invalid draft request"},
+		{http.MethodDelete, "/api/v1/tickets/abc/draft", "", "Invalid ticket ID"},
+		{http.MethodPut, "/api/v1/tickets/5/drafts/x", `{"body": "Hi", "version": 1}`, "Invalid draft ID"},
+		{http.MethodPut, "/api/v1/admin/article-drafts/settings", `{"retention_days": -1}`, "retention_days must be 0 to 3650"},
+	} {
+		t.Run(tc.method+" "+tc.path+" "+tc.body, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Contains(t, w.Body.String(), tc.want)
+		})
+	}
+}
+
+func TestArticleDraftHandlers_CustomerForbidden(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("user_id", 1)
+		c.Set("user_role", "Customer")
+		c.Next()
+	})
+	router.GET("/api/v1/tickets/:id/draft", HandleGetArticleDraftAPI)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/tickets/5/draft", nil))
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "agents only")
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/gin-gonic/gin"
 
 	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/repository"
 )
 
 // loadArticleAttachments fetches attachments for an article and returns them as a slice of maps.
@@ -114,6 +115,7 @@ func HandleListArticlesAPI(c *gin.Context) {
 	defer rows.Close()
 
 	articles := []gin.H{}
+	var articleIDs []int64
 	for rows.Next() {
 		var article struct {
 			ID                  int
@@ -172,9 +174,22 @@ func HandleListArticlesAPI(c *gin.Context) {
 		}
 
 		articles = append(articles, articleData)
+		articleIDs = append(articleIDs, int64(article.ID))
 	}
 	_ = rows.Err() //nolint:errcheck // Iteration errors don't affect response
 
+	// Email replies also carry what the sender wrote without the messages
+	// they quoted; body stays the full message
+	if texts, err := repository.NewArticleVisibleTextRepository(db).GetForArticles(c.Request.Context(), articleIDs); err == nil {
+		for i, id := range articleIDs {
+			if vt := texts[id]; vt != nil {
+				articles[i]["visible_body"] = vt.VisibleText
+				articles[i]["has_quoted_text"] = vt.HasQuote
+				articles[i]["has_signature"] = vt.HasSignature
+			}
+		}
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"articles": articles,
 		"total":    len(articles),
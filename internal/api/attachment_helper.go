@@ -1,8 +1,11 @@
 package api
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
+	"errors"
+	"fmt"
 	"io"
 	"log"
 	"mime/multipart"
@@ -10,30 +13,22 @@ import (
 	"strings"
 	"time"
 
-	"github.com/goatkit/goatflow/internal/config"
+	"github.com/goatkit/goatflow/internal/antivirus"
 	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/repository"
 	"github.com/goatkit/goatflow/internal/service"
+	"github.com/goatkit/goatflow/internal/sysconfig"
 )
 
-type attachmentConfig struct {
-	maxSize      int64
-	allowedTypes map[string]struct{}
-}
-
-func loadAttachmentConfig() attachmentConfig {
-	cfg := attachmentConfig{
-		maxSize:      10 * 1024 * 1024,
-		allowedTypes: map[string]struct{}{},
-	}
-	if appCfg := config.Get(); appCfg != nil {
-		if appCfg.Storage.Attachments.MaxSize > 0 {
-			cfg.maxSize = appCfg.Storage.Attachments.MaxSize
-		}
-		for _, t := range appCfg.Storage.Attachments.AllowedTypes {
-			cfg.allowedTypes[strings.ToLower(t)] = struct{}{}
-		}
+// attachmentPolicy returns the upload policy of a role. When the policies
+// cannot be loaded, the configuration file limits apply.
+func attachmentPolicy(db *sql.DB, role string) sysconfig.AttachmentPolicy {
+	policy, err := service.NewAttachmentUploadService(db).Policy(role)
+	if err != nil {
+		log.Printf("attachment policy: %v", err)
 	}
-	return cfg
+	return policy
 }
 
 var attachmentBlockedExtensions = map[string]bool{
@@ -45,17 +40,6 @@ func isBlockedExtension(filename string) bool {
 	return attachmentBlockedExtensions[strings.ToLower(filepath.Ext(filename))]
 }
 
-func isAllowedContentType(contentType string, allowed map[string]struct{}) bool {
-	if len(allowed) == 0 {
-		return true
-	}
-	if contentType == "" || contentType == "application/octet-stream" {
-		return true
-	}
-	_, ok := allowed[strings.ToLower(contentType)]
-	return ok
-}
-
 func detectFileContentType(fh *multipart.FileHeader, f multipart.File) string {
 	contentType := fh.Header.Get("Content-Type")
 	if contentType != "" && contentType != "application/octet-stream" {
@@ -73,66 +57,113 @@ func detectFileContentType(fh *multipart.FileHeader, f multipart.File) string {
 	return contentType
 }
 
+// scanAttachment runs the antivirus check on an uploaded attachment before
+// it is stored. An error means it must not be stored; it was quarantined.
+func scanAttachment(ctx context.Context, db *sql.DB, in service.AttachmentScanInput) error {
+	if antivirus.Default() == nil || db == nil {
+		return nil
+	}
+	in.Source = models.AttachmentSourceUpload
+	_, err := service.NewDefaultAttachmentScanService(db).Check(ctx, in)
+	return err
+}
+
+// scanUploadedFile reads f for scanAttachment and rewinds it.
+func scanUploadedFile(ctx context.Context, db *sql.DB, f multipart.File, in service.AttachmentScanInput) error {
+	if antivirus.Default() == nil || db == nil {
+		return nil
+	}
+	content, err := io.ReadAll(f)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	in.Content = content
+	return scanAttachment(ctx, db, in)
+}
+
 type attachmentProcessParams struct {
 	ctx       context.Context
 	db        *sql.DB
 	ticketID  int
 	articleID int
 	userID    int
+	role      string // Whose attachment policy applies; agents when empty
 }
 
-func processFormAttachments(files []*multipart.FileHeader, params attachmentProcessParams) {
+// processFormAttachments stores the uploaded files with the article. Files
+// the uploader's attachment policy does not allow are skipped; the reasons
+// are returned.
+func processFormAttachments(files []*multipart.FileHeader, params attachmentProcessParams) []string {
 	if len(files) == 0 {
-		return
+		return nil
 	}
 
-	cfg := loadAttachmentConfig()
+	if params.role == "" {
+		params.role = service.AttachmentRoleAgent
+	}
+	svc := service.NewAttachmentUploadService(params.db)
+	policy := attachmentPolicy(params.db, params.role)
 	storageSvc := GetStorageService()
 
+	var rejected []string
 	for _, fh := range files {
 		if fh == nil {
 			continue
 		}
-		if fh.Size > cfg.maxSize {
-			log.Printf("attachment too large: %s", fh.Filename)
-			continue
-		}
 		if isBlockedExtension(fh.Filename) {
 			log.Printf("blocked file type: %s", fh.Filename)
+			rejected = append(rejected, fh.Filename+": file type not allowed")
 			continue
 		}
-		processOneAttachment(fh, cfg, storageSvc, params)
+		if err := processOneAttachment(fh, svc, policy, storageSvc, params); err != nil {
+			log.Printf("attachment %s not stored: %v", fh.Filename, err)
+			_, msg, _ := attachmentPolicyMessage(err)
+			rejected = append(rejected, fh.Filename+": "+msg)
+		}
 	}
+	return rejected
 }
 
 func processOneAttachment(
-	fh *multipart.FileHeader, cfg attachmentConfig, storageSvc service.StorageService, params attachmentProcessParams,
-) {
+	fh *multipart.FileHeader, svc *service.AttachmentUploadService, policy sysconfig.AttachmentPolicy,
+	storageSvc service.StorageService, params attachmentProcessParams,
+) error {
 	f, err := fh.Open()
 	if err != nil {
-		log.Printf("open attachment failed: %v", err)
-		return
+		return err
 	}
 	defer f.Close()
 
 	contentType := detectFileContentType(fh, f)
-	if !isAllowedContentType(contentType, cfg.allowedTypes) {
-		log.Printf("type not allowed: %s %s", fh.Filename, contentType)
-		return
+	if err := svc.Check(params.ctx, policy, params.ticketID, normalizeMimeType(contentType), fh.Size); err != nil {
+		return err
+	}
+
+	if err := scanUploadedFile(params.ctx, params.db, f, service.AttachmentScanInput{
+		TicketID:    params.ticketID,
+		ArticleID:   params.articleID,
+		Filename:    fh.Filename,
+		ContentType: contentType,
+		UserID:      params.userID,
+	}); err != nil {
+		return errAttachmentQuarantined
 	}
 
 	ctx := service.WithUserID(params.ctx, params.userID)
 	ctx = service.WithArticleID(ctx, params.articleID)
 	storagePath := service.GenerateOTRSStoragePath(params.ticketID, params.articleID, fh.Filename)
 	if _, err := storageSvc.Store(ctx, f, fh, storagePath); err != nil {
-		log.Printf("storage Store failed: %v", err)
-		return
+		return fmt.Errorf("storage Store failed: %w", err)
 	}
 
 	if _, isDB := storageSvc.(*service.DatabaseStorageService); isDB {
-		return
+		return nil
 	}
 	insertAttachmentMetadata(fh, contentType, params)
+	return nil
 }
 
 func insertAttachmentMetadata(fh *multipart.FileHeader, contentType string, params attachmentProcessParams) {
@@ -178,3 +209,96 @@ func getFormFiles(form *multipart.Form) []*multipart.FileHeader {
 	}
 	return files
 }
+
+// errAttachmentQuarantined is returned when the virus scanner kept a file
+// from being stored.
+var errAttachmentQuarantined = errors.New("attachment was quarantined by the virus scanner")
+
+// storeTicketAttachment stores a file with an article of the ticket: the
+// given one, or else the latest, which is created when the ticket has none
+// yet. It returns the article the file was stored with.
+func storeTicketAttachment(
+	ctx context.Context, db *sql.DB, ticketID, articleID, userID int,
+	file multipart.File, header *multipart.FileHeader, contentType string,
+) (int, error) {
+	if articleID <= 0 {
+		articleRepo := repository.NewArticleRepository(db)
+		latest, err := articleRepo.GetLatestArticleForTicket(uint(ticketID))
+		if err != nil || latest == nil || latest.ID == 0 {
+			latest = &models.Article{
+				TicketID:               ticketID,
+				ArticleTypeID:          2,
+				SenderTypeID:           3,
+				CommunicationChannelID: 1,
+				IsVisibleForCustomer:   1,
+				Subject:                "Attachment",
+				Body:                   "",
+				CreateBy:               userID,
+				ChangeBy:               userID,
+			}
+			if err := articleRepo.Create(latest); err != nil {
+				return 0, fmt.Errorf("create article for attachment: %w", err)
+			}
+		}
+		articleID = latest.ID
+	}
+
+	storageSvc := GetStorageService()
+	if storageSvc == nil {
+		return articleID, errors.New("storage service unavailable")
+	}
+	// Pass article_id for the DB backend and user_id for its audit fields
+	ctx = service.WithArticleID(ctx, articleID)
+	ctx = service.WithUserID(ctx, userID)
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return articleID, err
+	}
+	if err := scanUploadedFile(ctx, db, file, service.AttachmentScanInput{
+		TicketID:    ticketID,
+		ArticleID:   articleID,
+		Filename:    header.Filename,
+		ContentType: contentType,
+		UserID:      userID,
+	}); err != nil {
+		return articleID, errAttachmentQuarantined
+	}
+	storagePath := service.GenerateOTRSStoragePath(ticketID, articleID, header.Filename)
+	if _, err := storageSvc.Store(ctx, file, header, storagePath); err != nil {
+		return articleID, fmt.Errorf("store attachment: %w", err)
+	}
+
+	// Other backends keep the file elsewhere; the DB row lists it with the article
+	if _, isDB := storageSvc.(*service.DatabaseStorageService); isDB {
+		return articleID, nil
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return articleID, err
+	}
+	content, err := io.ReadAll(file)
+	if err != nil {
+		return articleID, fmt.Errorf("read attachment: %w", err)
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	now := time.Now()
+	if _, err := db.ExecContext(ctx, database.ConvertPlaceholders(`
+		INSERT INTO article_data_mime_attachment (
+			article_id, filename, content_type, content_size, content,
+			disposition, create_time, create_by, change_time, change_by
+		) VALUES (?,?,?,?,?,?,?,?,?,?)`),
+		articleID, header.Filename, contentType, int64(len(content)), content,
+		"attachment", now, userID, now, userID,
+	); err != nil {
+		return articleID, fmt.Errorf("record attachment: %w", err)
+	}
+	return articleID, nil
+}
+
+// memoryFile serves content assembled in memory, such as a chunked upload,
+// as an uploaded file.
+type memoryFile struct {
+	*bytes.Reader
+}
+
+func (memoryFile) Close() error { return nil }
@@ -0,0 +1,207 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/preview"
+	"github.com/goatkit/goatflow/internal/service"
+	"github.com/goatkit/goatflow/internal/storage"
+)
+
+// previewAttachment is what the preview endpoint needs to know about an
+// attachment before deciding whether the caller may see it.
+type previewAttachment struct {
+	filename        string
+	contentType     string
+	articleID       int
+	ticketID        int
+	queueID         uint
+	customerUserID  string
+	visibleCustomer bool
+}
+
+// HandleGetAttachmentPreview handles GET /api/v1/attachments/:id/preview.
+//
+//	@Summary		Get attachment preview
+//	@Description	Returns a thumbnail (size=small, PNG) or inline preview (size=large, WebP) of an image attachment, or of the first page of a PDF when a converter is configured. Previews are cached after the first request. Attachments without a preview get a placeholder icon. Agents need read access to the ticket's queue; customers only see attachments of their own tickets that are visible to them.
+//	@Tags			Attachments
+//	@Produce		png
+//	@Param			id		path		int		true	"Attachment ID"
+//	@Param			size	query		string	false	"small (default) or large"
+//	@Success		200		{file}		binary	"Preview image"
+//	@Failure		400		{object}	map[string]interface{}	"Invalid attachment ID or size"
+//	@Failure		403		{object}	map[string]interface{}	"No access to the ticket"
+//	@Failure		404		{object}	map[string]interface{}	"Attachment not found"
+//	@Failure		503		{object}	map[string]interface{}	"Previews are disabled"
+//	@Security		BearerAuth
+//	@Router			/attachments/{id}/preview [get]
+func HandleGetAttachmentPreview(c *gin.Context) {
+	svc := preview.Default()
+	if svc == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"success": false, "error": "Attachment previews are disabled"})
+		return
+	}
+	attID, err := strconv.Atoi(c.Param("id"))
+	if err != nil || attID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid attachment ID"})
+		return
+	}
+	size, err := preview.ParseSize(c.Query("size"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	db, err := database.GetDB()
+	if err != nil || db == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"success": false, "error": "Database unavailable"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	att, err := loadPreviewAttachment(ctx, db, attID)
+	if err != nil {
+		log.Printf("attachment preview api: load attachment %d: %v", attID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to load attachment"})
+		return
+	}
+	if att == nil || !previewVisible(c, db, att) {
+		return
+	}
+
+	var loadErr error
+	p, err := svc.Get(ctx, attID, size, func(ctx context.Context) ([]byte, string, error) {
+		content, err := loadPreviewContent(ctx, db, attID, att)
+		if err != nil {
+			loadErr = err
+			return nil, "", err
+		}
+		contentType := normalizeMimeType(att.contentType)
+		if contentType == "" || contentType == "application/octet-stream" {
+			contentType = detectContentType(att.filename, content[:min(len(content), 512)])
+		}
+		return content, contentType, nil
+	})
+	switch {
+	case loadErr != nil:
+		log.Printf("attachment preview api: load content of attachment %d: %v", attID, loadErr)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to load attachment"})
+		return
+	case err != nil:
+		if !errors.Is(err, preview.ErrUnsupported) {
+			log.Printf("attachment preview api: preview of attachment %d (%s): %v", attID, att.contentType, err)
+		}
+		ph, phType := service.GetPlaceholderThumbnail(att.contentType)
+		c.Header("Cache-Control", "private, max-age=3600")
+		c.Data(http.StatusOK, phType, ph)
+		return
+	}
+	c.Header("Cache-Control", "private, max-age=86400")
+	c.Data(http.StatusOK, p.ContentType, p.Content)
+}
+
+// loadPreviewAttachment returns the attachment with its ticket, or nil.
+func loadPreviewAttachment(ctx context.Context, db *sql.DB, attID int) (*previewAttachment, error) {
+	var (
+		att     previewAttachment
+		visible sql.NullInt64
+	)
+	err := db.QueryRowContext(ctx, database.ConvertPlaceholders(`
+		SELECT att.filename, COALESCE(att.content_type, ''), att.article_id, a.ticket_id,
+			a.is_visible_for_customer, t.queue_id, COALESCE(t.customer_user_id, '')
+		FROM article_data_mime_attachment att
+		JOIN article a ON a.id = att.article_id
+		JOIN ticket t ON t.id = a.ticket_id
+		WHERE att.id = ?`), attID).
+		Scan(&att.filename, &att.contentType, &att.articleID, &att.ticketID,
+			&visible, &att.queueID, &att.customerUserID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	att.visibleCustomer = visible.Int64 == 1
+	return &att, nil
+}
+
+// previewVisible tells whether the caller may see the attachment, writing
+// the error response when not. Customers get 404 for attachments of other
+// customers, so their IDs cannot be probed.
+func previewVisible(c *gin.Context, db *sql.DB, att *previewAttachment) bool {
+	if attachmentRole(c) == service.AttachmentRoleCustomer {
+		login := c.GetString("username")
+		if login == "" || login != att.customerUserID || !att.visibleCustomer {
+			c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Attachment not found"})
+			return false
+		}
+		return true
+	}
+
+	userID := uint(GetUserIDFromCtx(c, 0))
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"success": false, "error": "Authentication required"})
+		return false
+	}
+	access := service.NewQueueAccessService(db)
+	ctx := c.Request.Context()
+	ok, err := access.IsAdmin(ctx, userID)
+	if err == nil && !ok {
+		ok, err = access.HasQueueAccess(ctx, userID, att.queueID, "ro")
+	}
+	if err != nil {
+		log.Printf("attachment preview api: check queue access: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to check permissions"})
+		return false
+	}
+	if !ok {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": "You do not have permission to access this queue"})
+		return false
+	}
+	return true
+}
+
+// loadPreviewContent reads the attachment content from the database, or
+// from the storage backend when the row only holds metadata.
+func loadPreviewContent(ctx context.Context, db *sql.DB, attID int, att *previewAttachment) ([]byte, error) {
+	var content []byte
+	if err := db.QueryRowContext(ctx, database.ConvertPlaceholders(
+		"SELECT content FROM article_data_mime_attachment WHERE id = ?"), attID).Scan(&content); err != nil {
+		return nil, err
+	}
+	content = storage.DecodeBytes(content)
+	if len(content) > 0 {
+		return content, nil
+	}
+	if ss := GetStorageService(); ss != nil {
+		path := service.GenerateOTRSStoragePath(att.ticketID, att.articleID, att.filename)
+		if rc, err := ss.Retrieve(ctx, path); err == nil {
+			defer rc.Close()
+			if buf, err := io.ReadAll(rc); err == nil && len(buf) > 0 {
+				return buf, nil
+			}
+		}
+	}
+	if buf, ok := findLocalStoredAttachmentBytes(att.ticketID, att.filename); ok {
+		return buf, nil
+	}
+	return nil, errors.New("attachment content not found")
+}
+
+// invalidateAttachmentPreview drops the cached previews of a deleted
+// attachment.
+func invalidateAttachmentPreview(ctx context.Context, attID int) {
+	if svc := preview.Default(); svc != nil {
+		if err := svc.Invalidate(ctx, attID); err != nil {
+			log.Printf("attachment preview: removing cached previews of attachment %d failed: %v", attID, err)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/goatkit/goatflow/internal/preview"
+)
+
+func TestHandleGetAttachmentPreview_InvalidRequest(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/api/v1/attachments/:id/preview", HandleGetAttachmentPreview)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	preview.SetDefault(nil)
+	w := get("/api/v1/attachments/1/preview")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Contains(t, w.Body.String(), "previews are disabled")
+
+	preview.SetDefault(preview.NewService(preview.NewDiskCache(t.TempDir()), nil))
+	t.Cleanup(func() { preview.SetDefault(nil) })
+
+	w = get("/api/v1/attachments/abc/preview")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "Invalid attachment ID")
+
+	w = get("/api/v1/attachments/1/preview?size=huge")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "use small or large")
+}
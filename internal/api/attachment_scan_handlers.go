@@ -0,0 +1,195 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/service"
+)
+
+// attachmentScanService returns the service, writing 503 when the database
+// is unavailable.
+func attachmentScanService(c *gin.Context) *service.AttachmentScanService {
+	db, err := database.GetDB()
+	if err != nil || db == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"success": false, "error": "Database unavailable"})
+		return nil
+	}
+	return service.NewDefaultAttachmentScanService(db)
+}
+
+// attachmentScanError maps AttachmentScanService errors to responses.
+func attachmentScanError(c *gin.Context, err error, action string) {
+	switch {
+	case errors.Is(err, service.ErrQuarantineNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Quarantined attachment not found"})
+	case errors.Is(err, service.ErrQuarantineReleased):
+		c.JSON(http.StatusConflict, gin.H{"success": false, "error": err.Error()})
+	default:
+		log.Printf("attachment scan api: %s failed: %v", action, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to " + action})
+	}
+}
+
+// quarantineID parses the :id path parameter, writing 400 when it is
+// invalid.
+func quarantineID(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid quarantine ID"})
+		return 0, false
+	}
+	return id, true
+}
+
+// HandleGetAttachmentScannerAPI handles GET /api/v1/admin/attachments/scanner.
+//
+//	@Summary		Get attachment scanner status
+//	@Description	Whether attachments are scanned and whether the scanner answers, with its version.
+//	@Tags			Attachment Scanning
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}	"Scanner status"
+//	@Security		BearerAuth
+//	@Router			/admin/attachments/scanner [get]
+func HandleGetAttachmentScannerAPI(c *gin.Context) {
+	svc := attachmentScanService(c)
+	if svc == nil {
+		return
+	}
+	status := gin.H{"enabled": svc.Enabled()}
+	if svc.Enabled() {
+		name, version, err := svc.ScannerVersion(c.Request.Context())
+		status["scanner"] = name
+		status["reachable"] = err == nil
+		if err != nil {
+			status["error"] = err.Error()
+		} else {
+			status["version"] = version
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": status})
+}
+
+// HandleListQuarantineAPI handles GET /api/v1/admin/attachments/quarantine.
+//
+//	@Summary		List quarantined attachments
+//	@Tags			Attachment Scanning
+//	@Produce		json
+//	@Param			include_released	query		bool	false	"Include released attachments"
+//	@Param			limit				query		int		false	"Maximum entries (default 50)"
+//	@Param			offset				query		int		false	"Offset"
+//	@Success		200					{object}	map[string]interface{}	"Quarantined attachments, newest first"
+//	@Security		BearerAuth
+//	@Router			/admin/attachments/quarantine [get]
+func HandleListQuarantineAPI(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if limit <= 0 || limit > 500 {
+		limit = 50
+	}
+	offset, _ := strconv.Atoi(c.Query("offset"))
+	if offset < 0 {
+		offset = 0
+	}
+	includeReleased, _ := strconv.ParseBool(c.Query("include_released"))
+	svc := attachmentScanService(c)
+	if svc == nil {
+		return
+	}
+	list, err := svc.ListQuarantine(c.Request.Context(), includeReleased, limit, offset)
+	if err != nil {
+		attachmentScanError(c, err, "load quarantine")
+		return
+	}
+	if list == nil {
+		list = []*models.QuarantinedAttachment{}
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": list})
+}
+
+// HandleGetQuarantineAPI handles GET /api/v1/admin/attachments/quarantine/:id.
+//
+//	@Summary		Get quarantined attachment
+//	@Tags			Attachment Scanning
+//	@Produce		json
+//	@Param			id	path		int	true	"Quarantine ID"
+//	@Success		200	{object}	map[string]interface{}	"Quarantined attachment"
+//	@Failure		404	{object}	map[string]interface{}	"Quarantined attachment not found"
+//	@Security		BearerAuth
+//	@Router			/admin/attachments/quarantine/{id} [get]
+func HandleGetQuarantineAPI(c *gin.Context) {
+	id, ok := quarantineID(c)
+	if !ok {
+		return
+	}
+	svc := attachmentScanService(c)
+	if svc == nil {
+		return
+	}
+	q, err := svc.GetQuarantine(c.Request.Context(), id)
+	if err != nil {
+		attachmentScanError(c, err, "load quarantined attachment")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": q})
+}
+
+// HandleReleaseQuarantineAPI handles POST /api/v1/admin/attachments/quarantine/:id/release.
+//
+//	@Summary		Release quarantined attachment
+//	@Description	Adds the attachment to its article after all, for false positives.
+//	@Tags			Attachment Scanning
+//	@Produce		json
+//	@Param			id	path		int	true	"Quarantine ID"
+//	@Success		200	{object}	map[string]interface{}	"Attachment released"
+//	@Failure		404	{object}	map[string]interface{}	"Quarantined attachment not found"
+//	@Failure		409	{object}	map[string]interface{}	"Already released"
+//	@Security		BearerAuth
+//	@Router			/admin/attachments/quarantine/{id}/release [post]
+func HandleReleaseQuarantineAPI(c *gin.Context) {
+	id, ok := quarantineID(c)
+	if !ok {
+		return
+	}
+	svc := attachmentScanService(c)
+	if svc == nil {
+		return
+	}
+	q, err := svc.Release(c.Request.Context(), id, GetUserIDFromCtx(c, 1))
+	if err != nil {
+		attachmentScanError(c, err, "release quarantined attachment")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": q})
+}
+
+// HandleDeleteQuarantineAPI handles DELETE /api/v1/admin/attachments/quarantine/:id.
+//
+//	@Summary		Delete quarantined attachment
+//	@Tags			Attachment Scanning
+//	@Produce		json
+//	@Param			id	path		int	true	"Quarantine ID"
+//	@Success		200	{object}	map[string]interface{}	"Quarantined attachment deleted"
+//	@Failure		404	{object}	map[string]interface{}	"Quarantined attachment not found"
+//	@Security		BearerAuth
+//	@Router			/admin/attachments/quarantine/{id} [delete]
+func HandleDeleteQuarantineAPI(c *gin.Context) {
+	id, ok := quarantineID(c)
+	if !ok {
+		return
+	}
+	svc := attachmentScanService(c)
+	if svc == nil {
+		return
+	}
+	if err := svc.DeleteQuarantine(c.Request.Context(), id); err != nil {
+		attachmentScanError(c, err, "delete quarantined attachment")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
@@ -0,0 +1,36 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAttachmentQuarantineAPI_InvalidID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.GET("/api/v1/admin/attachments/quarantine/:id", HandleGetQuarantineAPI)
+	router.POST("/api/v1/admin/attachments/quarantine/:id/release", HandleReleaseQuarantineAPI)
+	router.DELETE("/api/v1/admin/attachments/quarantine/:id", HandleDeleteQuarantineAPI)
+
+	for _, tc := range []struct {
+		method string
+		path   string
+	}{
+		{http.MethodGet, "/api/v1/admin/attachments/quarantine/abc"},
+		{http.MethodPost, "/api/v1/admin/attachments/quarantine/0/release"},
+		{http.MethodDelete, "/api/v1/admin/attachments/quarantine/-1"},
+	} {
+		t.Run(tc.method+" "+tc.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(tc.method, tc.path, nil))
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Contains(t, w.Body.String(), "Invalid quarantine ID")
+		})
+	}
+}
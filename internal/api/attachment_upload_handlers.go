@@ -0,0 +1,368 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/repository"
+	"github.com/goatkit/goatflow/internal/service"
+	"github.com/goatkit/goatflow/internal/sysconfig"
+)
+
+// uploadOffsetHeader carries the byte offset of a chunk, and the received
+// size of an upload in answers.
+const uploadOffsetHeader = "Upload-Offset"
+
+// attachmentUploadService returns the service, writing 503 when the
+// database is unavailable.
+func attachmentUploadService(c *gin.Context) *service.AttachmentUploadService {
+	db, err := database.GetDB()
+	if err != nil || db == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"success": false, "error": "Database unavailable"})
+		return nil
+	}
+	return service.NewAttachmentUploadService(db)
+}
+
+// attachmentRole returns the role whose attachment policy applies to the
+// caller.
+func attachmentRole(c *gin.Context) string {
+	switch role := c.GetString("user_role"); {
+	case c.GetBool("is_customer") || role == service.AttachmentRoleCustomer:
+		return service.AttachmentRoleCustomer
+	case role == service.AttachmentRoleAdmin:
+		return service.AttachmentRoleAdmin
+	}
+	return service.AttachmentRoleAgent
+}
+
+// attachmentPolicyMessage returns the message shown for an attachment that
+// was not stored, and its status; ok is false for unexpected errors.
+func attachmentPolicyMessage(err error) (int, string, bool) {
+	var policyErr *service.AttachmentPolicyError
+	if errors.As(err, &policyErr) {
+		switch policyErr.Err {
+		case service.ErrAttachmentTooLarge:
+			return http.StatusRequestEntityTooLarge,
+				"File size exceeds maximum of " + formatFileSize(policyErr.Limit), true
+		case service.ErrAttachmentTicketTotal:
+			return http.StatusRequestEntityTooLarge,
+				"Ticket total size limit exceeded (max " + formatFileSize(policyErr.Limit) + ")", true
+		case service.ErrAttachmentTypeNotAllowed:
+			return http.StatusBadRequest, "File type not allowed", true
+		}
+	}
+	switch {
+	case errors.Is(err, errAttachmentQuarantined):
+		return http.StatusUnprocessableEntity, "Attachment was quarantined by the virus scanner", true
+	case errors.Is(err, service.ErrAttachmentUploadNotFound):
+		return http.StatusNotFound, "Upload not found", true
+	case errors.Is(err, service.ErrAttachmentUploadInvalid), errors.Is(err, service.ErrAttachmentPolicySettings):
+		return http.StatusBadRequest, err.Error(), true
+	case errors.Is(err, service.ErrAttachmentUploadOffset):
+		return http.StatusConflict, "Chunk does not continue the upload; resume from its received size", true
+	case errors.Is(err, service.ErrAttachmentUploadPending):
+		return http.StatusConflict, "Upload is not complete", true
+	}
+	return http.StatusInternalServerError, "Attachment could not be stored", false
+}
+
+// attachmentUploadError maps AttachmentUploadService errors to responses.
+func attachmentUploadError(c *gin.Context, err error, action string) {
+	status, msg, ok := attachmentPolicyMessage(err)
+	if !ok {
+		log.Printf("attachment upload api: %s failed: %v", action, err)
+		msg = "Failed to " + action
+	}
+	c.JSON(status, gin.H{"success": false, "error": msg})
+}
+
+// HandleStartAttachmentUpload handles POST /api/v1/tickets/:id/uploads.
+//
+//	@Summary		Start chunked attachment upload
+//	@Description	Starts a resumable upload of a large attachment. The file's name, size and type are checked against the caller's attachment policy before any chunk is sent. Chunks are then sent with PATCH, and the upload is attached once complete. Uploads without a chunk for a day are removed.
+//	@Tags			Attachments
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		string	true	"Ticket ID or number"
+//	@Param			upload	body		object	true	"filename, size, content_type, article_id"
+//	@Success		201		{object}	map[string]interface{}	"Upload started"
+//	@Failure		400		{object}	map[string]interface{}	"Invalid upload or file type not allowed"
+//	@Failure		404		{object}	map[string]interface{}	"Ticket not found"
+//	@Failure		413		{object}	map[string]interface{}	"File or ticket total too large"
+//	@Security		BearerAuth
+//	@Router			/tickets/{id}/uploads [post]
+func HandleStartAttachmentUpload(c *gin.Context) {
+	if attachmentRole(c) == service.AttachmentRoleCustomer {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": "Chunked uploads are available to agents"})
+		return
+	}
+	var req service.AttachmentUploadStart
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid request body"})
+		return
+	}
+	svc := attachmentUploadService(c)
+	if svc == nil {
+		return
+	}
+	ticketID, err := resolveTicketID(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Ticket not found"})
+		return
+	}
+	if req.ArticleID > 0 {
+		db, _ := database.GetDB() //nolint:errcheck // checked by attachmentUploadService
+		art, err := repository.NewArticleRepository(db).GetByID(uint(req.ArticleID))
+		if err != nil || art == nil || art.TicketID != ticketID {
+			c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Article not found"})
+			return
+		}
+	}
+	if isBlockedExtension(req.Filename) {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "File type not allowed"})
+		return
+	}
+	req.TicketID = ticketID
+	req.ContentType = normalizeMimeType(req.ContentType)
+	u, err := svc.Start(c.Request.Context(), req, GetUserIDFromCtx(c, 1), attachmentRole(c))
+	if err != nil {
+		attachmentUploadError(c, err, "start upload")
+		return
+	}
+	c.Header("Location", "/api/v1/uploads/"+u.ID)
+	c.JSON(http.StatusCreated, gin.H{"success": true, "data": gin.H{
+		"upload":         u,
+		"max_chunk_size": service.AttachmentUploadMaxChunk,
+	}})
+}
+
+// HandleGetAttachmentUpload handles GET /api/v1/uploads/:upload_id.
+//
+//	@Summary		Get chunked upload status
+//	@Description	Returns how many bytes of an upload were received, to resume it after an interruption. The received size is also in the Upload-Offset header.
+//	@Tags			Attachments
+//	@Produce		json
+//	@Param			upload_id	path		string	true	"Upload ID"
+//	@Success		200			{object}	map[string]interface{}	"Upload"
+//	@Failure		404			{object}	map[string]interface{}	"Upload not found"
+//	@Security		BearerAuth
+//	@Router			/uploads/{upload_id} [get]
+func HandleGetAttachmentUpload(c *gin.Context) {
+	svc := attachmentUploadService(c)
+	if svc == nil {
+		return
+	}
+	u, err := svc.Get(c.Request.Context(), c.Param("upload_id"), GetUserIDFromCtx(c, 1))
+	if err != nil {
+		attachmentUploadError(c, err, "load upload")
+		return
+	}
+	c.Header(uploadOffsetHeader, strconv.FormatInt(u.ReceivedSize, 10))
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": u})
+}
+
+// HandleAppendAttachmentUpload handles PATCH /api/v1/uploads/:upload_id.
+//
+//	@Summary		Send upload chunk
+//	@Description	Appends the request body to the upload. The Upload-Offset header must equal the bytes received so far; otherwise nothing is stored and 409 returns the received size to resume from.
+//	@Tags			Attachments
+//	@Accept			application/octet-stream
+//	@Produce		json
+//	@Param			upload_id		path		string	true	"Upload ID"
+//	@Param			Upload-Offset	header		integer	true	"Byte offset of the chunk"
+//	@Success		200				{object}	map[string]interface{}	"Chunk stored"
+//	@Failure		400				{object}	map[string]interface{}	"Invalid chunk"
+//	@Failure		404				{object}	map[string]interface{}	"Upload not found"
+//	@Failure		409				{object}	map[string]interface{}	"Offset does not match"
+//	@Security		BearerAuth
+//	@Router			/uploads/{upload_id} [patch]
+func HandleAppendAttachmentUpload(c *gin.Context) {
+	offset, err := strconv.ParseInt(c.GetHeader(uploadOffsetHeader), 10, 64)
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Upload-Offset header is required"})
+		return
+	}
+	chunk, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, service.AttachmentUploadMaxChunk))
+	if err != nil {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"success": false,
+			"error": fmt.Sprintf("Chunks may be at most %s", formatFileSize(service.AttachmentUploadMaxChunk))})
+		return
+	}
+	svc := attachmentUploadService(c)
+	if svc == nil {
+		return
+	}
+	u, err := svc.Append(c.Request.Context(), c.Param("upload_id"), GetUserIDFromCtx(c, 1), offset, chunk)
+	if errors.Is(err, service.ErrAttachmentUploadOffset) {
+		status, msg, _ := attachmentPolicyMessage(err)
+		c.Header(uploadOffsetHeader, strconv.FormatInt(u.ReceivedSize, 10))
+		c.JSON(status, gin.H{"success": false, "error": msg, "data": u})
+		return
+	}
+	if err != nil {
+		attachmentUploadError(c, err, "store chunk")
+		return
+	}
+	c.Header(uploadOffsetHeader, strconv.FormatInt(u.ReceivedSize, 10))
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": u})
+}
+
+// HandleCompleteAttachmentUpload handles POST /api/v1/uploads/:upload_id/complete.
+//
+//	@Summary		Complete chunked upload
+//	@Description	Assembles the received chunks, checks the file against the attachment policy again, scans it and attaches it to the ticket: to the article given when the upload started, or the latest one. The upload is removed.
+//	@Tags			Attachments
+//	@Produce		json
+//	@Param			upload_id	path		string	true	"Upload ID"
+//	@Success		201			{object}	map[string]interface{}	"Attachment stored"
+//	@Failure		400			{object}	map[string]interface{}	"File type not allowed"
+//	@Failure		404			{object}	map[string]interface{}	"Upload not found"
+//	@Failure		409			{object}	map[string]interface{}	"Upload not complete"
+//	@Failure		413			{object}	map[string]interface{}	"File or ticket total too large"
+//	@Failure		422			{object}	map[string]interface{}	"Quarantined by the virus scanner"
+//	@Security		BearerAuth
+//	@Router			/uploads/{upload_id}/complete [post]
+func HandleCompleteAttachmentUpload(c *gin.Context) {
+	svc := attachmentUploadService(c)
+	if svc == nil {
+		return
+	}
+	ctx := c.Request.Context()
+	userID := GetUserIDFromCtx(c, 1)
+	u, content, err := svc.Assemble(ctx, c.Param("upload_id"), userID)
+	if err != nil {
+		attachmentUploadError(c, err, "complete upload")
+		return
+	}
+
+	contentType := normalizeMimeType(u.ContentType)
+	if contentType == "" || contentType == "application/octet-stream" {
+		contentType = normalizeMimeType(detectContentType(u.Filename, content[:min(len(content), 512)]))
+	}
+	db, _ := database.GetDB() //nolint:errcheck // checked by attachmentUploadService
+	// The ticket may have gained attachments while the chunks were sent
+	err = svc.Check(ctx, attachmentPolicy(db, u.UserRole), u.TicketID, contentType, u.TotalSize)
+	if err == nil {
+		header := &multipart.FileHeader{
+			Filename: u.Filename,
+			Size:     u.TotalSize,
+			Header:   textproto.MIMEHeader{"Content-Type": {contentType}},
+		}
+		u.ArticleID, err = storeTicketAttachment(ctx, db, u.TicketID, u.ArticleID, userID,
+			memoryFile{bytes.NewReader(content)}, header, contentType)
+	}
+	// Files that were refused cannot become acceptable, so the upload goes either way
+	if derr := svc.Discard(ctx, u.ID, userID); derr != nil {
+		log.Printf("attachment upload api: removing upload %s failed: %v", u.ID, derr)
+	}
+	if err != nil {
+		attachmentUploadError(c, err, "store attachment")
+		return
+	}
+	c.Header("HX-Trigger", "attachments-updated")
+	c.JSON(http.StatusCreated, gin.H{"success": true, "data": gin.H{
+		"ticket_id":    u.TicketID,
+		"article_id":   u.ArticleID,
+		"filename":     u.Filename,
+		"size":         u.TotalSize,
+		"content_type": contentType,
+	}})
+}
+
+// HandleCancelAttachmentUpload handles DELETE /api/v1/uploads/:upload_id.
+//
+//	@Summary		Cancel chunked upload
+//	@Description	Removes an upload and the chunks received so far.
+//	@Tags			Attachments
+//	@Produce		json
+//	@Param			upload_id	path		string	true	"Upload ID"
+//	@Success		200			{object}	map[string]interface{}	"Upload cancelled"
+//	@Failure		404			{object}	map[string]interface{}	"Upload not found"
+//	@Security		BearerAuth
+//	@Router			/uploads/{upload_id} [delete]
+func HandleCancelAttachmentUpload(c *gin.Context) {
+	svc := attachmentUploadService(c)
+	if svc == nil {
+		return
+	}
+	if err := svc.Discard(c.Request.Context(), c.Param("upload_id"), GetUserIDFromCtx(c, 1)); err != nil {
+		attachmentUploadError(c, err, "cancel upload")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Upload cancelled"})
+}
+
+// HandleGetAttachmentPolicyAPI handles GET /api/v1/admin/attachments/policy.
+//
+//	@Summary		Get attachment policies
+//	@Description	Returns the attachment upload policies: the default and the overrides per role, plus the policy that results for each role.
+//	@Tags			Attachments
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}	"Attachment policies"
+//	@Security		BearerAuth
+//	@Router			/admin/attachments/policy [get]
+func HandleGetAttachmentPolicyAPI(c *gin.Context) {
+	svc := attachmentUploadService(c)
+	if svc == nil {
+		return
+	}
+	cfg, err := svc.Settings()
+	if err != nil {
+		attachmentUploadError(c, err, "load attachment policies")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": attachmentPolicyData(svc, cfg)})
+}
+
+// HandleUpdateAttachmentPolicyAPI handles PUT /api/v1/admin/attachments/policy.
+//
+//	@Summary		Update attachment policies
+//	@Description	Sets the maximum file size, allowed MIME types and maximum total per ticket, by default and per role (Admin, Agent, Customer). Zero sizes and empty type lists inherit from the default, which inherits from storage.attachments of the configuration file.
+//	@Tags			Attachments
+//	@Accept			json
+//	@Produce		json
+//	@Param			policies	body		object	true	"default and roles"
+//	@Success		200			{object}	map[string]interface{}	"Attachment policies"
+//	@Failure		400			{object}	map[string]interface{}	"Invalid policies"
+//	@Security		BearerAuth
+//	@Router			/admin/attachments/policy [put]
+func HandleUpdateAttachmentPolicyAPI(c *gin.Context) {
+	var cfg sysconfig.AttachmentPolicyConfig
+	if err := c.ShouldBindJSON(&cfg); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid request body"})
+		return
+	}
+	svc := attachmentUploadService(c)
+	if svc == nil {
+		return
+	}
+	saved, err := svc.SaveSettings(cfg, GetUserIDFromCtx(c, 1))
+	if err != nil {
+		attachmentUploadError(c, err, "save attachment policies")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": attachmentPolicyData(svc, saved)})
+}
+
+// attachmentPolicyData adds the resulting policy of every role to the
+// stored ones, so admins see what the inheritance amounts to.
+func attachmentPolicyData(svc *service.AttachmentUploadService, cfg sysconfig.AttachmentPolicyConfig) gin.H {
+	effective := gin.H{}
+	for _, role := range []string{service.AttachmentRoleAdmin, service.AttachmentRoleAgent, service.AttachmentRoleCustomer} {
+		if p, err := svc.Policy(role); err == nil {
+			effective[role] = p
+		}
+	}
+	return gin.H{"default": cfg.Default, "roles": cfg.Roles, "effective": effective}
+}
@@ -0,0 +1,101 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/goatkit/goatflow/internal/service"
+)
+
+func TestAttachmentUploadHandlers_InvalidRequest(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		if c.GetHeader("X-Test-Customer") != "" {
+			c.Set("user_role", "Customer")
+		}
+	})
+	router.POST("/api/v1/tickets/:id/uploads", HandleStartAttachmentUpload)
+	router.PATCH("/api/v1/uploads/:upload_id", HandleAppendAttachmentUpload)
+	router.PUT("/api/v1/admin/attachments/policy", HandleUpdateAttachmentPolicyAPI)
+
+	for _, tc := range []struct {
+		method   string
+		path     string
+		body     string
+		customer bool
+		status   int
+		want     string
+	}{
+		{http.MethodPost, "/api/v1/tickets/1/uploads", `{"filename": "a.zip", "size": 1}`, true, http.StatusForbidden, "available to agents"},
+		{http.MethodPost, "/api/v1/tickets/1/uploads", `{"size": "big"}`, false, http.StatusBadRequest, "Invalid request body"},
+		{http.MethodPatch, "/api/v1/uploads/abc", `chunk`, false, http.StatusBadRequest, "Upload-Offset header is required"},
+		{http.MethodPut, "/api/v1/admin/attachments/policy", `{"default": {"max_file_size": "1MB"}}`, false, http.StatusBadRequest, "Invalid request body"},
+	} {
+		t.Run(tc.method+" "+tc.path+" "+tc.body, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", "application/json")
+			if tc.customer {
+				req.Header.Set("X-Test-Customer", "1")
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.status, w.Code)
+			assert.Contains(t, w.Body.String(), tc.want)
+		})
+	}
+}
+
+func TestAttachmentPolicyMessage(t *testing.T) {
+	for _, tc := range []struct {
+		err    error
+		status int
+		want   string
+		known  bool
+	}{
+		{&service.AttachmentPolicyError{Err: service.ErrAttachmentTooLarge, Limit: 5 << 20}, http.StatusRequestEntityTooLarge, "File size exceeds maximum of 5.00 MB", true},
+		{&service.AttachmentPolicyError{Err: service.ErrAttachmentTicketTotal, Limit: 1 << 30}, http.StatusRequestEntityTooLarge, "max 1.00 GB", true},
+		{&service.AttachmentPolicyError{Err: service.ErrAttachmentTypeNotAllowed, ContentType: "text/html"}, http.StatusBadRequest, "File type not allowed", true},
+		{errAttachmentQuarantined, http.StatusUnprocessableEntity, "quarantined", true},
+		{fmt.Errorf("%w: empty chunk", service.ErrAttachmentUploadInvalid), http.StatusBadRequest, "empty chunk", true},
+		{service.ErrAttachmentUploadOffset, http.StatusConflict, "resume", true},
+		{service.ErrAttachmentUploadPending, http.StatusConflict, "not complete", true},
+		{service.ErrAttachmentUploadNotFound, http.StatusNotFound, "Upload not found", true},
+		{errors.New("disk full"), http.StatusInternalServerError, "could not be stored", false},
+	} {
+		status, msg, known := attachmentPolicyMessage(tc.err)
+		assert.Equal(t, tc.status, status, tc.err.Error())
+		assert.Contains(t, msg, tc.want)
+		assert.Equal(t, tc.known, known, tc.err.Error())
+	}
+}
+
+func TestAttachmentRole(t *testing.T) {
+	for _, tc := range []struct {
+		role     string
+		customer bool
+		want     string
+	}{
+		{"", false, service.AttachmentRoleAgent},
+		{"Agent", false, service.AttachmentRoleAgent},
+		{"Admin", false, service.AttachmentRoleAdmin},
+		{"Customer", false, service.AttachmentRoleCustomer},
+		{"", true, service.AttachmentRoleCustomer},
+	} {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		if tc.role != "" {
+			c.Set("user_role", tc.role)
+		}
+		c.Set("is_customer", tc.customer)
+		assert.Equal(t, tc.want, attachmentRole(c), "%q customer=%v", tc.role, tc.customer)
+	}
+}
@@ -79,6 +79,7 @@ func HandleLoginAPI(c *gin.Context) {
 	if err != nil {
 		auth.DefaultLoginRateLimiter.RecordFailure(clientIP, loginRequest.Login)
 		if err == auth.ErrInvalidCredentials {
+			recordAPILoginFailure(c.Request.Context(), db, loginRequest.Login)
 			c.JSON(http.StatusUnauthorized, gin.H{
 				"success": false,
 				"error":   "Invalid credentials",
@@ -97,12 +98,28 @@ func HandleLoginAPI(c *gin.Context) {
 		return
 	}
 
+	kind := service.PasswordAccountAgent
+	if strings.EqualFold(user.Role, "customer") {
+		kind = service.PasswordAccountCustomer
+	}
+	// Only admins sign in during an active maintenance window
+	if message := maintenanceLoginBlock(c.Request.Context(), db, kind, int(user.ID)); message != "" {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"success":     false,
+			"error":       message,
+			"maintenance": true,
+		})
+		return
+	}
+
 	// Clear rate limit on successful login
 	auth.DefaultLoginRateLimiter.RecordSuccess(clientIP, loginRequest.Login)
+	passwordExpired := recordLoginSuccess(c.Request.Context(), db, kind, user.Login, true)
 
 	// Return success with tokens
 	c.JSON(http.StatusOK, gin.H{
-		"success": true,
+		"success":          true,
+		"password_expired": passwordExpired,
 		"user": gin.H{
 			"id":         user.ID,
 			"login":      user.Login,
@@ -68,6 +68,11 @@ func handleCustomerLogin(jwtManager *auth.JWTManager) gin.HandlerFunc {
 			return
 		}
 
+		if d := portalDomainForRequest(c); d != nil && !d.PasswordLogin {
+			c.JSON(http.StatusForbidden, gin.H{"success": false, "error": "password login is disabled on this portal"})
+			return
+		}
+
 		db, err := database.GetDB()
 		if err != nil || db == nil {
 			c.JSON(http.StatusServiceUnavailable, gin.H{"success": false, "error": "database unavailable"})
@@ -82,18 +87,40 @@ func handleCustomerLogin(jwtManager *auth.JWTManager) gin.HandlerFunc {
 
 		authenticator := auth.NewAuthenticator(provider)
 		user, err := authenticator.Authenticate(c.Request.Context(), login, password)
-		if err != nil || user == nil || strings.ToLower(user.Role) != "customer" {
+		localLogin := err == nil && user != nil
+		if err != nil || user == nil {
+			// Fall back to the LDAP directories serving the customer login
+			if ldapUser, ok := authenticateLDAPCustomer(c.Request.Context(), db, login, password); ok {
+				user, err = ldapUser, nil
+			}
+		}
+		if err != nil || user == nil {
+			recordLoginFailure(c.Request.Context(), db, service.PasswordAccountCustomer, login)
+		}
+		// Customers of other companies cannot sign in on a company's portal domain
+		if err != nil || user == nil || strings.ToLower(user.Role) != "customer" || !portalDomainAllowsCustomer(c, user.Login) {
 			auth.DefaultLoginRateLimiter.RecordFailure(clientIP, login)
 			c.JSON(http.StatusUnauthorized, gin.H{"success": false, "error": "invalid credentials"})
 			return
 		}
 
+		// Customers cannot sign in during an active maintenance window
+		if message := maintenanceLoginBlock(c.Request.Context(), db, service.PasswordAccountCustomer, int(user.ID)); message != "" {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"success": false, "error": message, "maintenance": true})
+			return
+		}
+
 		// Clear rate limit on successful login
 		auth.DefaultLoginRateLimiter.RecordSuccess(clientIP, login)
+		// An expired password sends the customer to the change form first
+		landing := "/customer"
+		passwordExpired := recordLoginSuccess(c.Request.Context(), db, service.PasswordAccountCustomer, user.Login, localLogin)
+		if passwordExpired {
+			landing = "/customer/password/form?expired=1"
+		}
 
-		// Check if 2FA is enabled for this customer
-		totpService := service.NewTOTPService(db, "GoatFlow")
-		if totpService.IsEnabledForCustomer(user.Login) {
+		// Check if 2FA (TOTP or security key) is enabled for this customer
+		if customerHasSecondFactor(db, user.Login) {
 			// SECURITY FIX (V3/V4/V5/V7): Use session manager - customer login stored server-side
 			sessionMgr := auth.GetTOTPSessionManager()
 			token, err := sessionMgr.CreateCustomerSession(user.Login, c.ClientIP(), c.Request.UserAgent())
@@ -170,12 +197,12 @@ func handleCustomerLogin(jwtManager *auth.JWTManager) gin.HandlerFunc {
 			}
 		}
 
-		// Always redirect to customer dashboard after login
-		c.Header("HX-Redirect", "/customer")
+		c.Header("HX-Redirect", landing)
 		c.JSON(http.StatusOK, gin.H{
-			"success":      true,
-			"access_token": token,
-			"token_type":   "Bearer",
+			"success":          true,
+			"access_token":     token,
+			"token_type":       "Bearer",
+			"password_expired": passwordExpired,
 			"user": gin.H{
 				"id":         user.ID,
 				"login":      user.Login,
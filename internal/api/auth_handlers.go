@@ -112,9 +112,7 @@ var HandleAuthLogin = func(c *gin.Context) {
 
 	// Check if 2FA is enabled for this user
 	if db, err := database.GetDB(); err == nil && db != nil {
-		totpService := service.NewTOTPService(db, "GoatFlow")
-		is2FAEnabled := totpService.IsEnabled(int(user.ID))
-		if is2FAEnabled {
+		if agentHasSecondFactor(db, int(user.ID)) {
 			// 2FA is enabled - don't complete login yet
 			sessionMgr := auth.GetTOTPSessionManager()
 			token, err := sessionMgr.CreateAgentSession(int(user.ID), username, c.ClientIP(), c.Request.UserAgent())
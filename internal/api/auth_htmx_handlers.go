@@ -2,6 +2,7 @@ package api
 
 import (
 	"crypto/sha256"
+	"database/sql"
 	"encoding/hex"
 	"fmt"
 	"log"
@@ -17,8 +18,10 @@ import (
 	"github.com/goatkit/goatflow/internal/config"
 	"github.com/goatkit/goatflow/internal/constants"
 	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/models"
 	"github.com/goatkit/goatflow/internal/service"
 	"github.com/goatkit/goatflow/internal/shared"
+	"github.com/goatkit/goatflow/internal/sysconfig"
 )
 
 // handleLoginPage shows the login page.
@@ -33,16 +36,17 @@ func handleLoginPage(c *gin.Context) {
 
 	// Default to false if config is not initialized (e.g., in tests)
 	allowRegistration := false
-	allowLostPassword := false
 	if cfg != nil {
 		allowRegistration = cfg.Features.Registration
-		allowLostPassword = cfg.Features.LostPassword
 	}
+	// features.lost_password and the password reset settings
+	allowLostPassword := passwordResetEnabled(service.PasswordAccountAgent)
 
 	getPongo2Renderer().HTML(c, http.StatusOK, "pages/login.pongo2", pongo2.Context{
 		"error":             errorMsg,
 		"AllowRegistration": allowRegistration,
 		"AllowLostPassword": allowLostPassword,
+		"SSOProviders":      ssoLoginProviders(c, models.SSOFrontendAgent),
 	})
 }
 
@@ -59,9 +63,23 @@ func handleCustomerLoginPage(c *gin.Context) {
 
 	errorMsg := c.Query("error")
 
-	getPongo2Renderer().HTML(c, http.StatusOK, "pages/customer/login.pongo2", pongo2.Context{
-		"error": errorMsg,
-	})
+	tmplCtx := pongo2.Context{
+		"error":             errorMsg,
+		"SSOProviders":      ssoLoginProviders(c, models.SSOFrontendCustomer),
+		"AllowRegistration": customerRegistrationEnabled(),
+		"AllowLostPassword": passwordResetEnabled(service.PasswordAccountCustomer),
+	}
+	if d := portalDomainForRequest(c); d != nil {
+		// Brand the login page for the customer company's portal domain
+		if db, err := database.GetDB(); err == nil && db != nil {
+			if cfg, err := sysconfig.LoadCustomerPortalConfigForCompany(db, d.CustomerID); err == nil {
+				cfg.Domain, cfg.Theme, cfg.LogoURL = d.Domain, d.Theme, d.LogoURL
+				tmplCtx["Portal"] = cfg
+			}
+		}
+		tmplCtx["PasswordLoginDisabled"] = !d.PasswordLogin
+	}
+	getPongo2Renderer().HTML(c, http.StatusOK, "pages/customer/login.pongo2", tmplCtx)
 }
 
 // handleLogin processes login requests.
@@ -142,8 +160,19 @@ func handleLogin(jwtManager *auth.JWTManager) gin.HandlerFunc {
 			}
 		}
 
+		localLogin := validLogin
+		if !validLogin {
+			// Fall back to the LDAP directories serving the agent login
+			if ldapUserID, ldapLogin, ok := authenticateLDAPAgent(c.Request.Context(), db, username, password); ok {
+				validLogin = true
+				userID = uint(ldapUserID)
+				username = ldapLogin
+			}
+		}
+
 		if !validLogin {
 			auth.DefaultLoginRateLimiter.RecordFailure(clientIP, username)
+			recordLoginFailure(c.Request.Context(), db, service.PasswordAccountAgent, username)
 			isHXRequest := c.GetHeader("HX-Request") == "true"
 			isJSONRequest := strings.Contains(c.GetHeader("Accept"), "application/json")
 			renderMissing := getPongo2Renderer() == nil || getPongo2Renderer().TemplateSet() == nil
@@ -160,11 +189,33 @@ func handleLogin(jwtManager *auth.JWTManager) gin.HandlerFunc {
 			return
 		}
 
+		// Only admins sign in during an active maintenance window
+		if message := maintenanceLoginBlock(c.Request.Context(), db, service.PasswordAccountAgent, int(userID)); message != "" {
+			isHXRequest := c.GetHeader("HX-Request") == "true"
+			isJSONRequest := strings.Contains(c.GetHeader("Accept"), "application/json")
+			if isHXRequest || isJSONRequest || getPongo2Renderer() == nil || getPongo2Renderer().TemplateSet() == nil {
+				c.JSON(http.StatusServiceUnavailable, gin.H{
+					"success":     false,
+					"error":       message,
+					"maintenance": true,
+				})
+				return
+			}
+			getPongo2Renderer().HTML(c, http.StatusServiceUnavailable, "pages/login.pongo2", pongo2.Context{
+				"Error": message,
+			})
+			return
+		}
+
 		auth.DefaultLoginRateLimiter.RecordSuccess(clientIP, username)
+		// An expired password sends the agent to the change form first
+		landing := "/dashboard"
+		if recordLoginSuccess(c.Request.Context(), db, service.PasswordAccountAgent, username, localLogin) {
+			landing = "/agent/password?expired=1"
+		}
 
-		// Check if 2FA is enabled for this user
-		totpService := service.NewTOTPService(db, "GoatFlow")
-		if totpService.IsEnabled(int(userID)) {
+		// Check if 2FA (TOTP or security key) is enabled for this user
+		if agentHasSecondFactor(db, int(userID)) {
 			// 2FA is enabled - don't complete login yet
 			// SECURITY FIX (V3/V4/V5/V7): Use session manager instead of raw cookies
 			sessionMgr := auth.GetTOTPSessionManager()
@@ -249,15 +300,15 @@ func handleLogin(jwtManager *auth.JWTManager) gin.HandlerFunc {
 		}
 
 		if c.GetHeader("HX-Request") == "true" {
-			c.Header("HX-Redirect", "/dashboard")
+			c.Header("HX-Redirect", landing)
 			c.JSON(http.StatusOK, gin.H{
 				"success":  true,
-				"redirect": "/dashboard",
+				"redirect": landing,
 			})
 			return
 		}
 
-		c.Redirect(http.StatusFound, "/dashboard")
+		c.Redirect(http.StatusFound, landing)
 	}
 }
 
@@ -409,11 +460,12 @@ func loginRedirectPath(c *gin.Context) string {
 
 // handle2FAPage shows the 2FA verification page.
 func handle2FAPage(c *gin.Context) {
-	if _, err := c.Cookie("2fa_pending"); err != nil {
+	token, err := c.Cookie("2fa_pending")
+	if err != nil {
 		c.Redirect(http.StatusFound, "/login")
 		return
 	}
-	getPongo2Renderer().HTML(c, http.StatusOK, "pages/login_2fa.pongo2", pongo2.Context{})
+	getPongo2Renderer().HTML(c, http.StatusOK, "pages/login_2fa.pongo2", secondFactorPageContext(c, token))
 }
 
 // handle2FAVerify processes the 2FA verification during login.
@@ -499,76 +551,95 @@ func handle2FAVerify(jwtManager *auth.JWTManager) gin.HandlerFunc {
 		sessionMgr.InvalidateSession(pendingToken)
 		c.SetCookie("2fa_pending", "", -1, "/", "", false, true)
 
-		// Complete the login - generate token and set cookies
-		var token string
-		if jwtManager != nil {
-			tokenStr, err := jwtManager.GenerateToken(uint(userID), username, "user", 1)
-			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{
-					"success": false,
-					"error":   "Failed to generate token",
-				})
-				return
-			}
-			token = tokenStr
+		completeAgentTwoFactorLogin(c, jwtManager, db, userID, username)
+	}
+}
+
+// completeAgentTwoFactorLogin issues the session for an agent who passed the
+// second factor (TOTP, recovery code or security key). The token carries the
+// mfa claim so the two-factor policy middleware lets the session through.
+func completeAgentTwoFactorLogin(c *gin.Context, jwtManager *auth.JWTManager, db *sql.DB, userID int, username string) {
+	startAgentSession(c, jwtManager, db, userID, username, true)
+}
+
+// startAgentSession issues the agent's token and session cookies and sends
+// the browser to the dashboard. mfa marks sessions that passed a second factor.
+func startAgentSession(c *gin.Context, jwtManager *auth.JWTManager, db *sql.DB, userID int, username string, mfa bool) {
+	// Complete the login - generate token and set cookies
+	var token string
+	if jwtManager != nil {
+		var tokenStr string
+		var err error
+		if mfa {
+			tokenStr, err = jwtManager.GenerateTwoFactorToken(uint(userID), username, username, "user", false, 1)
 		} else {
-			token = fmt.Sprintf("demo_session_%d_%d", userID, time.Now().Unix())
+			tokenStr, err = jwtManager.GenerateToken(uint(userID), username, "user", 1)
 		}
-
-		sessionTimeout := constants.DefaultSessionTimeout
-		var userTheme, userThemeMode string
-		prefService := service.NewUserPreferencesService(db)
-		if userTimeout := prefService.GetSessionTimeout(userID); userTimeout > 0 {
-			sessionTimeout = userTimeout
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"error":   "Failed to generate token",
+			})
+			return
 		}
-		userTheme = prefService.GetTheme(userID)
-		userThemeMode = prefService.GetThemeMode(userID)
+		token = tokenStr
+	} else {
+		token = fmt.Sprintf("demo_session_%d_%d", userID, time.Now().Unix())
+	}
 
-		c.SetCookie("access_token", token, sessionTimeout, "/", "", false, true)
-		c.SetCookie("auth_token", token, sessionTimeout, "/", "", false, true)
-		c.SetCookie("goatflow_logged_in", "1", sessionTimeout, "/", "", false, false)
+	sessionTimeout := constants.DefaultSessionTimeout
+	var userTheme, userThemeMode string
+	prefService := service.NewUserPreferencesService(db)
+	if userTimeout := prefService.GetSessionTimeout(userID); userTimeout > 0 {
+		sessionTimeout = userTimeout
+	}
+	userTheme = prefService.GetTheme(userID)
+	userThemeMode = prefService.GetThemeMode(userID)
 
-		if userTheme != "" {
-			c.SetCookie("goatflow_theme", userTheme, sessionTimeout, "/", "", false, false)
-		}
-		if userThemeMode != "" {
-			c.SetCookie("goatflow_mode", userThemeMode, sessionTimeout, "/", "", false, false)
-		}
+	c.SetCookie("access_token", token, sessionTimeout, "/", "", false, true)
+	c.SetCookie("auth_token", token, sessionTimeout, "/", "", false, true)
+	c.SetCookie("goatflow_logged_in", "1", sessionTimeout, "/", "", false, false)
 
-		// Create session record
-		if sessionSvc := shared.GetSessionService(); sessionSvc != nil {
-			sessionID, err := sessionSvc.CreateSession(
-				userID,
-				username,
-				"User",
-				c.ClientIP(),
-				c.Request.UserAgent(),
-			)
-			if err != nil {
-				log.Printf("Failed to create session record: %v", err)
-			} else {
-				c.SetCookie("session_id", sessionID, sessionTimeout, "/", "", false, true)
-			}
-		}
+	if userTheme != "" {
+		c.SetCookie("goatflow_theme", userTheme, sessionTimeout, "/", "", false, false)
+	}
+	if userThemeMode != "" {
+		c.SetCookie("goatflow_mode", userThemeMode, sessionTimeout, "/", "", false, false)
+	}
 
-		// Respond based on request type
-		contentType := c.GetHeader("Content-Type")
-		if c.GetHeader("HX-Request") == "true" {
-			c.Header("HX-Redirect", "/dashboard")
-			c.JSON(http.StatusOK, gin.H{
-				"success":  true,
-				"redirect": "/dashboard",
-			})
-			return
-		} else if strings.Contains(contentType, "application/json") {
-			// JSON fetch request (from login_2fa.pongo2 form)
-			c.JSON(http.StatusOK, gin.H{
-				"success":  true,
-				"redirect": "/dashboard",
-			})
-			return
+	// Create session record
+	if sessionSvc := shared.GetSessionService(); sessionSvc != nil {
+		sessionID, err := sessionSvc.CreateSession(
+			userID,
+			username,
+			"User",
+			c.ClientIP(),
+			c.Request.UserAgent(),
+		)
+		if err != nil {
+			log.Printf("Failed to create session record: %v", err)
+		} else {
+			c.SetCookie("session_id", sessionID, sessionTimeout, "/", "", false, true)
 		}
+	}
 
-		c.Redirect(http.StatusFound, "/dashboard")
+	// Respond based on request type
+	contentType := c.GetHeader("Content-Type")
+	if c.GetHeader("HX-Request") == "true" {
+		c.Header("HX-Redirect", "/dashboard")
+		c.JSON(http.StatusOK, gin.H{
+			"success":  true,
+			"redirect": "/dashboard",
+		})
+		return
+	} else if strings.Contains(contentType, "application/json") {
+		// JSON fetch request (from login_2fa.pongo2 form)
+		c.JSON(http.StatusOK, gin.H{
+			"success":  true,
+			"redirect": "/dashboard",
+		})
+		return
 	}
+
+	c.Redirect(http.StatusFound, "/dashboard")
 }
@@ -0,0 +1,311 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/service"
+)
+
+// autoResponseRequest is the JSON body of the auto response create and
+// update handlers.
+type autoResponseRequest struct {
+	Name            string `json:"name"`
+	TypeID          int    `json:"type_id"`
+	SystemAddressID int    `json:"system_address_id"`
+	Subject         string `json:"subject"`
+	Body            string `json:"body"`
+	ContentType     string `json:"content_type"`
+	Comments        string `json:"comments"`
+	ValidID         int    `json:"valid_id"`
+}
+
+func (r *autoResponseRequest) autoResponse(id int) *models.AutoResponse {
+	return &models.AutoResponse{
+		ID:              id,
+		Name:            r.Name,
+		TypeID:          r.TypeID,
+		SystemAddressID: r.SystemAddressID,
+		Subject:         r.Subject,
+		Body:            r.Body,
+		ContentType:     r.ContentType,
+		Comments:        r.Comments,
+		ValidID:         r.ValidID,
+	}
+}
+
+// queueAutoResponsesRequest is the JSON body of
+// PUT /api/v1/queues/:id/auto-responses.
+type queueAutoResponsesRequest struct {
+	AutoResponseIDs []int `json:"auto_response_ids"`
+}
+
+// autoResponseService returns the service, writing 503 when the database
+// is unavailable.
+func autoResponseService(c *gin.Context) *service.AutoResponseService {
+	db, err := database.GetDB()
+	if err != nil || db == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"success": false, "error": "Database unavailable"})
+		return nil
+	}
+	return service.NewAutoResponseService(db)
+}
+
+// autoResponseError maps AutoResponseService errors to responses.
+func autoResponseError(c *gin.Context, err error, action string) {
+	switch {
+	case errors.Is(err, service.ErrAutoResponseNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Auto response not found"})
+	case errors.Is(err, service.ErrAutoResponseQueueNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Queue not found"})
+	case errors.Is(err, service.ErrAutoResponseInvalid):
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+	default:
+		log.Printf("auto response api: %s failed: %v", action, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to " + action})
+	}
+}
+
+// autoResponseParamID parses a positive integer path parameter, writing 400
+// when it is invalid.
+func autoResponseParamID(c *gin.Context, what string) (int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid " + what + " ID"})
+		return 0, false
+	}
+	return id, true
+}
+
+// HandleListAutoResponsesAPI handles GET /api/v1/auto-responses.
+//
+//	@Summary		List auto responses
+//	@Description	Lists all auto responses with the queues they are assigned to.
+//	@Tags			Auto Responses
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}	"Auto responses"
+//	@Security		BearerAuth
+//	@Router			/auto-responses [get]
+func HandleListAutoResponsesAPI(c *gin.Context) {
+	svc := autoResponseService(c)
+	if svc == nil {
+		return
+	}
+	responses, err := svc.List(c.Request.Context())
+	if err != nil {
+		autoResponseError(c, err, "load auto responses")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": responses})
+}
+
+// HandleListAutoResponseTypesAPI handles GET /api/v1/auto-response-types.
+//
+//	@Summary		List auto response types
+//	@Description	Lists the events auto responses are sent for: new ticket, follow-up, reject, new ticket from a follow-up.
+//	@Tags			Auto Responses
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}	"Auto response types"
+//	@Security		BearerAuth
+//	@Router			/auto-response-types [get]
+func HandleListAutoResponseTypesAPI(c *gin.Context) {
+	svc := autoResponseService(c)
+	if svc == nil {
+		return
+	}
+	types, err := svc.Types(c.Request.Context())
+	if err != nil {
+		autoResponseError(c, err, "load auto response types")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": types})
+}
+
+// HandleGetAutoResponseAPI handles GET /api/v1/auto-responses/:id.
+//
+//	@Summary		Get auto response
+//	@Tags			Auto Responses
+//	@Produce		json
+//	@Param			id	path		int	true	"Auto response ID"
+//	@Success		200	{object}	map[string]interface{}	"Auto response"
+//	@Failure		404	{object}	map[string]interface{}	"Not found"
+//	@Security		BearerAuth
+//	@Router			/auto-responses/{id} [get]
+func HandleGetAutoResponseAPI(c *gin.Context) {
+	id, ok := autoResponseParamID(c, "auto response")
+	if !ok {
+		return
+	}
+	svc := autoResponseService(c)
+	if svc == nil {
+		return
+	}
+	ar, err := svc.Get(c.Request.Context(), id)
+	if err != nil {
+		autoResponseError(c, err, "load auto response")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": ar})
+}
+
+// HandleCreateAutoResponseAPI handles POST /api/v1/auto-responses.
+//
+//	@Summary		Create auto response
+//	@Description	Creates an auto response. Subject and body may contain <OTRS_*> / <GOATFLOW_*> placeholders.
+//	@Tags			Auto Responses
+//	@Accept			json
+//	@Produce		json
+//	@Param			auto_response	body		object	true	"Auto response"
+//	@Success		201				{object}	map[string]interface{}	"Created auto response"
+//	@Failure		400				{object}	map[string]interface{}	"Invalid auto response"
+//	@Security		BearerAuth
+//	@Router			/auto-responses [post]
+func HandleCreateAutoResponseAPI(c *gin.Context) {
+	var req autoResponseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid request body"})
+		return
+	}
+	svc := autoResponseService(c)
+	if svc == nil {
+		return
+	}
+	ar := req.autoResponse(0)
+	if err := svc.Create(c.Request.Context(), ar, GetUserIDFromCtx(c, 1)); err != nil {
+		autoResponseError(c, err, "create auto response")
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"success": true, "data": ar})
+}
+
+// HandleUpdateAutoResponseAPI handles PUT /api/v1/auto-responses/:id.
+//
+//	@Summary		Update auto response
+//	@Description	Updates an auto response. The type of an auto response assigned to queues cannot change.
+//	@Tags			Auto Responses
+//	@Accept			json
+//	@Produce		json
+//	@Param			id				path		int		true	"Auto response ID"
+//	@Param			auto_response	body		object	true	"Auto response"
+//	@Success		200				{object}	map[string]interface{}	"Updated auto response"
+//	@Failure		400				{object}	map[string]interface{}	"Invalid auto response"
+//	@Failure		404				{object}	map[string]interface{}	"Not found"
+//	@Security		BearerAuth
+//	@Router			/auto-responses/{id} [put]
+func HandleUpdateAutoResponseAPI(c *gin.Context) {
+	id, ok := autoResponseParamID(c, "auto response")
+	if !ok {
+		return
+	}
+	var req autoResponseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid request body"})
+		return
+	}
+	svc := autoResponseService(c)
+	if svc == nil {
+		return
+	}
+	ar := req.autoResponse(id)
+	if err := svc.Update(c.Request.Context(), ar, GetUserIDFromCtx(c, 1)); err != nil {
+		autoResponseError(c, err, "update auto response")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": ar})
+}
+
+// HandleDeleteAutoResponseAPI handles DELETE /api/v1/auto-responses/:id.
+//
+//	@Summary		Delete auto response
+//	@Description	Deletes an auto response and removes it from its queues.
+//	@Tags			Auto Responses
+//	@Produce		json
+//	@Param			id	path		int	true	"Auto response ID"
+//	@Success		200	{object}	map[string]interface{}	"Deleted"
+//	@Failure		404	{object}	map[string]interface{}	"Not found"
+//	@Security		BearerAuth
+//	@Router			/auto-responses/{id} [delete]
+func HandleDeleteAutoResponseAPI(c *gin.Context) {
+	id, ok := autoResponseParamID(c, "auto response")
+	if !ok {
+		return
+	}
+	svc := autoResponseService(c)
+	if svc == nil {
+		return
+	}
+	if err := svc.Delete(c.Request.Context(), id); err != nil {
+		autoResponseError(c, err, "delete auto response")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// HandleGetQueueAutoResponsesAPI handles GET /api/v1/queues/:id/auto-responses.
+//
+//	@Summary		List queue auto responses
+//	@Tags			Auto Responses
+//	@Produce		json
+//	@Param			id	path		int	true	"Queue ID"
+//	@Success		200	{object}	map[string]interface{}	"Auto responses of the queue"
+//	@Failure		404	{object}	map[string]interface{}	"Queue not found"
+//	@Security		BearerAuth
+//	@Router			/queues/{id}/auto-responses [get]
+func HandleGetQueueAutoResponsesAPI(c *gin.Context) {
+	queueID, ok := autoResponseParamID(c, "queue")
+	if !ok {
+		return
+	}
+	svc := autoResponseService(c)
+	if svc == nil {
+		return
+	}
+	responses, err := svc.QueueResponses(c.Request.Context(), queueID)
+	if err != nil {
+		autoResponseError(c, err, "load queue auto responses")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": responses})
+}
+
+// HandleSetQueueAutoResponsesAPI handles PUT /api/v1/queues/:id/auto-responses.
+//
+//	@Summary		Assign queue auto responses
+//	@Description	Replaces the auto responses of a queue; at most one per type.
+//	@Tags			Auto Responses
+//	@Accept			json
+//	@Produce		json
+//	@Param			id			path		int		true	"Queue ID"
+//	@Param			assignment	body		object	true	"auto_response_ids"
+//	@Success		200			{object}	map[string]interface{}	"Auto responses of the queue"
+//	@Failure		400			{object}	map[string]interface{}	"Invalid assignment"
+//	@Failure		404			{object}	map[string]interface{}	"Queue not found"
+//	@Security		BearerAuth
+//	@Router			/queues/{id}/auto-responses [put]
+func HandleSetQueueAutoResponsesAPI(c *gin.Context) {
+	queueID, ok := autoResponseParamID(c, "queue")
+	if !ok {
+		return
+	}
+	var req queueAutoResponsesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid request body"})
+		return
+	}
+	svc := autoResponseService(c)
+	if svc == nil {
+		return
+	}
+	responses, err := svc.SetQueueResponses(c.Request.Context(), queueID, req.AutoResponseIDs, GetUserIDFromCtx(c, 1))
+	if err != nil {
+		autoResponseError(c, err, "assign queue auto responses")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": responses})
+}
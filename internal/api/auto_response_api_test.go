@@ -0,0 +1,48 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAutoResponseHandlers_InvalidRequest(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("user_id", 1)
+		c.Next()
+	})
+	router.POST("/api/v1/auto-responses", HandleCreateAutoResponseAPI)
+	router.PUT("/api/v1/auto-responses/:id", HandleUpdateAutoResponseAPI)
+	router.DELETE("/api/v1/auto-responses/:id", HandleDeleteAutoResponseAPI)
+	router.PUT("/api/v1/queues/:id/auto-responses", HandleSetQueueAutoResponsesAPI)
+
+	for _, tc := range []struct {
+		method string
+		path   string
+		body   string
+		want   string
+	}{
+		{http.MethodPost, "/api/v1/auto-responses", `{"type_id": "reply"}`, "Invalid request body"},
+		{http.MethodPut, "/api/v1/auto-responses/0", `{"name": "Thanks"}`, "Invalid auto response ID"},
+		{http.MethodDelete, "/api/v1/auto-responses/abc", "", "Invalid auto response ID"},
+		{http.MethodPut, "/api/v1/queues/x/auto-responses", `{"auto_response_ids": [1]}`, "Invalid queue ID"},
+		{http.MethodPut, "/api/v1/queues/1/auto-responses", `{"auto_response_ids": "1"}`, "Invalid request body"},
+	} {
+		t.Run(tc.method+" "+tc.path+" "+tc.body, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Contains(t, w.Body.String(), tc.want)
+		})
+	}
+}
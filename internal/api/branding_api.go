@@ -0,0 +1,298 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/flosch/pongo2/v6"
+	"github.com/gin-gonic/gin"
+
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/service"
+	"github.com/goatkit/goatflow/internal/sysconfig"
+)
+
+// brandingTenantOption is a customer company offered on the appearance page.
+type brandingTenantOption struct {
+	CustomerID string `json:"customer_id"`
+	Name       string `json:"name"`
+}
+
+// brandingService returns the service, writing 503 when the database is
+// unavailable.
+func brandingService(c *gin.Context) *service.BrandingService {
+	db, err := database.GetDB()
+	if err != nil || db == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"success": false, "error": "Database unavailable"})
+		return nil
+	}
+	return service.NewBrandingService(db)
+}
+
+// brandingError maps BrandingService errors to responses.
+func brandingError(c *gin.Context, err error, action string) {
+	switch {
+	case errors.Is(err, service.ErrBrandingTenantNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Customer company not found"})
+	case errors.Is(err, service.ErrBrandingAssetNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Branding asset not found"})
+	case errors.Is(err, service.ErrBrandingInvalid):
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+	default:
+		log.Printf("branding api: %s failed: %v", action, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to " + action})
+	}
+}
+
+// brandingAssetKind returns the kind path parameter, writing 400 when it is
+// neither logo nor favicon.
+func brandingAssetKind(c *gin.Context) (string, bool) {
+	kind := c.Param("kind")
+	if kind != models.BrandingAssetLogo && kind != models.BrandingAssetFavicon {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid asset kind"})
+		return "", false
+	}
+	return kind, true
+}
+
+// fetchBrandingTenants returns the valid customer companies.
+func fetchBrandingTenants(db *sql.DB) ([]brandingTenantOption, error) {
+	rows, err := db.Query("SELECT customer_id, name FROM customer_company WHERE valid_id = 1 ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []brandingTenantOption
+	for rows.Next() {
+		var item brandingTenantOption
+		if err := rows.Scan(&item.CustomerID, &item.Name); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// handleAdminAppearance renders the branding settings page.
+func handleAdminAppearance(c *gin.Context) {
+	if htmxHandlerSkipDB() || getPongo2Renderer() == nil || getPongo2Renderer().TemplateSet() == nil {
+		c.Data(http.StatusOK, "text/html; charset=utf-8", []byte("<main>Appearance</main>"))
+		return
+	}
+
+	db, err := database.GetDB()
+	if err != nil || db == nil {
+		sendErrorResponse(c, http.StatusInternalServerError, "Database connection failed")
+		return
+	}
+
+	tenants, err := fetchBrandingTenants(db)
+	if err != nil {
+		sendErrorResponse(c, http.StatusInternalServerError, "Failed to load customer companies")
+		return
+	}
+
+	getPongo2Renderer().HTML(c, http.StatusOK, "pages/admin/appearance.pongo2", pongo2.Context{
+		"Tenants":    tenants,
+		"Defaults":   sysconfig.DefaultBrandingConfig(),
+		"ActivePage": "admin",
+		"User":       getUserMapForTemplate(c),
+	})
+}
+
+// HandleGetAppearanceAPI handles GET /api/v1/admin/appearance.
+//
+//	@Summary		Get branding settings
+//	@Description	Returns the branding settings and images stored for the global branding, or for a customer company when tenant is given, and the effective branding they result in.
+//	@Tags			Branding
+//	@Produce		json
+//	@Param			tenant	query		string	false	"Customer ID"
+//	@Success		200		{object}	map[string]interface{}	"Branding settings"
+//	@Failure		404		{object}	map[string]interface{}	"Customer company not found"
+//	@Security		BearerAuth
+//	@Router			/admin/appearance [get]
+func HandleGetAppearanceAPI(c *gin.Context) {
+	svc := brandingService(c)
+	if svc == nil {
+		return
+	}
+	tenant := c.Query("tenant")
+	settings, err := svc.Settings(c.Request.Context(), tenant)
+	if err != nil {
+		brandingError(c, err, "load branding")
+		return
+	}
+	assets, err := svc.Assets(c.Request.Context(), tenant)
+	if err != nil {
+		brandingError(c, err, "load branding")
+		return
+	}
+	effective, err := svc.Branding(c.Request.Context(), tenant)
+	if err != nil {
+		brandingError(c, err, "load branding")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{
+		"settings":  settings,
+		"assets":    assets,
+		"effective": effective,
+	}})
+}
+
+// HandleUpdateAppearanceAPI handles PUT /api/v1/admin/appearance.
+//
+//	@Summary		Update branding settings
+//	@Description	Stores the product name, colors, login page text and email header and footer. Empty tenant values inherit the global ones.
+//	@Tags			Branding
+//	@Accept			json
+//	@Produce		json
+//	@Param			tenant		query		string	false	"Customer ID"
+//	@Param			settings	body		object	true	"product_name, primary_color, accent_color, login_title, login_text, email_header, email_footer"
+//	@Success		200			{object}	map[string]interface{}	"Branding settings"
+//	@Failure		400			{object}	map[string]interface{}	"Invalid settings"
+//	@Failure		404			{object}	map[string]interface{}	"Customer company not found"
+//	@Security		BearerAuth
+//	@Router			/admin/appearance [put]
+func HandleUpdateAppearanceAPI(c *gin.Context) {
+	var cfg sysconfig.BrandingConfig
+	if err := c.ShouldBindJSON(&cfg); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid request body"})
+		return
+	}
+	svc := brandingService(c)
+	if svc == nil {
+		return
+	}
+	cfg, err := svc.SaveSettings(c.Request.Context(), c.Query("tenant"), cfg, GetUserIDFromCtx(c, 1))
+	if err != nil {
+		brandingError(c, err, "save branding")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": cfg})
+}
+
+// HandleUploadAppearanceAssetAPI handles POST /api/v1/admin/appearance/assets/:kind.
+//
+//	@Summary		Upload branding image
+//	@Description	Uploads the logo or favicon (PNG, JPEG, GIF, WebP, ICO or script-free SVG, at most 512 KiB), replacing the previous one.
+//	@Tags			Branding
+//	@Accept			multipart/form-data
+//	@Produce		json
+//	@Param			kind	path		string	true	"logo or favicon"
+//	@Param			tenant	query		string	false	"Customer ID"
+//	@Param			file	formData	file	true	"Image"
+//	@Success		201		{object}	map[string]interface{}	"Branding asset"
+//	@Failure		400		{object}	map[string]interface{}	"Invalid image"
+//	@Failure		404		{object}	map[string]interface{}	"Customer company not found"
+//	@Security		BearerAuth
+//	@Router			/admin/appearance/assets/{kind} [post]
+func HandleUploadAppearanceAssetAPI(c *gin.Context) {
+	kind, ok := brandingAssetKind(c)
+	if !ok {
+		return
+	}
+	content, header, err := readFormFile(c, "file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "File is required"})
+		return
+	}
+	svc := brandingService(c)
+	if svc == nil {
+		return
+	}
+	asset, err := svc.UploadAsset(c.Request.Context(), c.Query("tenant"), kind, header.Filename, content, GetUserIDFromCtx(c, 1))
+	if err != nil {
+		brandingError(c, err, "upload branding asset")
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"success": true, "data": asset})
+}
+
+// HandleDeleteAppearanceAssetAPI handles DELETE /api/v1/admin/appearance/assets/:kind.
+//
+//	@Summary		Delete branding image
+//	@Description	Removes the logo or favicon; a customer company then shows the global one again.
+//	@Tags			Branding
+//	@Produce		json
+//	@Param			kind	path		string	true	"logo or favicon"
+//	@Param			tenant	query		string	false	"Customer ID"
+//	@Success		200		{object}	map[string]interface{}	"Deleted"
+//	@Failure		404		{object}	map[string]interface{}	"Not found"
+//	@Security		BearerAuth
+//	@Router			/admin/appearance/assets/{kind} [delete]
+func HandleDeleteAppearanceAssetAPI(c *gin.Context) {
+	kind, ok := brandingAssetKind(c)
+	if !ok {
+		return
+	}
+	svc := brandingService(c)
+	if svc == nil {
+		return
+	}
+	if err := svc.DeleteAsset(c.Request.Context(), c.Query("tenant"), kind); err != nil {
+		brandingError(c, err, "delete branding asset")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// HandleGetBrandingAPI handles GET /api/v1/branding.
+//
+//	@Summary		Get effective branding
+//	@Description	Returns the branding pages are rendered with: that of the customer company given as tenant over the global one. Needs no authentication, as login pages show it.
+//	@Tags			Branding
+//	@Produce		json
+//	@Param			tenant	query		string	false	"Customer ID"
+//	@Success		200		{object}	map[string]interface{}	"Effective branding"
+//	@Router			/branding [get]
+func HandleGetBrandingAPI(c *gin.Context) {
+	svc := brandingService(c)
+	if svc == nil {
+		return
+	}
+	branding, err := svc.Branding(c.Request.Context(), c.Query("tenant"))
+	if err != nil {
+		brandingError(c, err, "load branding")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": branding})
+}
+
+// handleBrandingAsset serves a logo or favicon publicly, for login pages and
+// emails: the tenant's, else the global one. Versioned URLs are cached.
+func handleBrandingAsset(c *gin.Context) {
+	db, err := database.GetDB()
+	if err != nil || db == nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	asset, err := service.NewBrandingService(db).Asset(c.Request.Context(), c.Query("tenant"), c.Param("kind"))
+	if err != nil || asset == nil {
+		if err != nil && !errors.Is(err, service.ErrBrandingAssetNotFound) {
+			log.Printf("branding asset %q unavailable: %v", c.Param("kind"), err)
+		}
+		c.Status(http.StatusNotFound)
+		return
+	}
+	c.Header("X-Content-Type-Options", "nosniff")
+	if asset.ContentType == "image/svg+xml" {
+		c.Header("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; sandbox")
+	}
+	if c.Query("v") != "" {
+		c.Header("Cache-Control", "public, max-age=31536000, immutable")
+	} else {
+		c.Header("Cache-Control", "public, max-age=300")
+	}
+	c.Header("ETag", strconv.Quote(asset.Checksum))
+	if strings.TrimPrefix(c.GetHeader("If-None-Match"), "W/") == strconv.Quote(asset.Checksum) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	c.Data(http.StatusOK, asset.ContentType, asset.Content)
+}
@@ -0,0 +1,46 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBrandingHandlers_InvalidRequest(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("user_id", 1)
+		c.Next()
+	})
+	router.PUT("/api/v1/admin/appearance", HandleUpdateAppearanceAPI)
+	router.POST("/api/v1/admin/appearance/assets/:kind", HandleUploadAppearanceAssetAPI)
+	router.DELETE("/api/v1/admin/appearance/assets/:kind", HandleDeleteAppearanceAssetAPI)
+
+	for _, tc := range []struct {
+		method string
+		path   string
+		body   string
+		want   string
+	}{
+		{http.MethodPut, "/api/v1/admin/appearance", `{"product_name": 1}`, "Invalid request body"},
+		{http.MethodPost, "/api/v1/admin/appearance/assets/banner", "", "Invalid asset kind"},
+		{http.MethodPost, "/api/v1/admin/appearance/assets/logo", "", "File is required"},
+		{http.MethodDelete, "/api/v1/admin/appearance/assets/banner", "", "Invalid asset kind"},
+	} {
+		t.Run(tc.method+" "+tc.path+" "+tc.body, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Contains(t, w.Body.String(), tc.want)
+		})
+	}
+}
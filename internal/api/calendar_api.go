@@ -0,0 +1,877 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/goatkit/goatflow/internal/config"
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/service"
+)
+
+// calendarGroups returns the groups on which the agent has the permission
+// ("ro" or "rw"), or nil when the agent is an admin and may use every
+// calendar. Tests replace it to avoid a database.
+var calendarGroups = func(ctx context.Context, userID int, permission string) ([]int, error) {
+	db, err := database.GetDB()
+	if err != nil || db == nil {
+		return []int{}, err
+	}
+	access := service.NewQueueAccessService(db)
+	if admin, err := access.IsAdmin(ctx, uint(userID)); err != nil || admin {
+		return nil, err
+	}
+	ids, err := access.GetUserEffectiveGroupIDs(ctx, uint(userID), permission)
+	if err != nil {
+		return nil, err
+	}
+	groups := make([]int, 0, len(ids))
+	for _, id := range ids {
+		groups = append(groups, int(id))
+	}
+	return groups, nil
+}
+
+// calendarRequest is the JSON body accepted by the admin calendar
+// create/update handlers.
+type calendarRequest struct {
+	Name    string `json:"name" binding:"required"`
+	GroupID int    `json:"group_id" binding:"required"`
+	Color   string `json:"color"`
+	ValidID int    `json:"valid_id"`
+}
+
+// appointmentRequest is the JSON body accepted by the appointment
+// create/update handlers.
+type appointmentRequest struct {
+	CalendarID  int64     `json:"calendar_id"` // Moves the appointment on update
+	Title       string    `json:"title" binding:"required"`
+	Description string    `json:"description"`
+	Location    string    `json:"location"`
+	StartTime   time.Time `json:"start_time" binding:"required"`
+	EndTime     time.Time `json:"end_time" binding:"required"`
+	AllDay      bool      `json:"all_day"`
+	AttendeeIDs []int     `json:"attendee_ids"`
+	TicketIDs   []int64   `json:"ticket_ids"`
+}
+
+func (r *appointmentRequest) appointment(calendarID int64) *models.CalendarAppointment {
+	if r.CalendarID > 0 {
+		calendarID = r.CalendarID
+	}
+	tickets := make([]models.CalendarAppointmentTicket, 0, len(r.TicketIDs))
+	for _, id := range r.TicketIDs {
+		tickets = append(tickets, models.CalendarAppointmentTicket{ID: id})
+	}
+	return &models.CalendarAppointment{
+		CalendarID:  calendarID,
+		Title:       r.Title,
+		Description: r.Description,
+		Location:    r.Location,
+		StartTime:   r.StartTime,
+		EndTime:     r.EndTime,
+		AllDay:      r.AllDay,
+		AttendeeIDs: r.AttendeeIDs,
+		Tickets:     tickets,
+	}
+}
+
+// calendarView is a calendar as listed to an agent.
+type calendarView struct {
+	*models.Calendar
+	Writable bool `json:"writable"`
+}
+
+// calendarService returns the service, writing 503 when the database is
+// unavailable.
+func calendarService(c *gin.Context) *service.CalendarService {
+	db, err := database.GetDB()
+	if err != nil || db == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"success": false, "error": "Database unavailable"})
+		return nil
+	}
+	return service.NewCalendarService(db)
+}
+
+// calendarError maps CalendarService errors to responses.
+func calendarError(c *gin.Context, err error, action string) {
+	switch {
+	case errors.Is(err, service.ErrCalendarNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Calendar not found"})
+	case errors.Is(err, service.ErrAppointmentNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Appointment not found"})
+	case errors.Is(err, service.ErrCalendarNameExists):
+		c.JSON(http.StatusConflict, gin.H{"success": false, "error": err.Error()})
+	case errors.Is(err, service.ErrCalendarInvalid), errors.Is(err, service.ErrAppointmentInvalid):
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+	default:
+		log.Printf("calendar api: %s failed: %v", action, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to " + action})
+	}
+}
+
+// calendarParam parses an ID path parameter, writing 400 when it is
+// invalid.
+func calendarParam(c *gin.Context, name, what string) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param(name), 10, 64)
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid " + what + " ID"})
+		return 0, false
+	}
+	return id, true
+}
+
+// calendarGroupAllowed reports whether groups, as returned by
+// calendarGroups, contain the group.
+func calendarGroupAllowed(groups []int, groupID int) bool {
+	if groups == nil {
+		return true
+	}
+	for _, id := range groups {
+		if id == groupID {
+			return true
+		}
+	}
+	return false
+}
+
+// calendarFor loads a calendar the agent has the permission on. Calendars
+// the agent cannot read are reported as missing; read-only calendars are
+// forbidden for writes.
+func calendarFor(c *gin.Context, svc *service.CalendarService, userID int, id int64, permission string) (*models.Calendar, bool) {
+	ctx := c.Request.Context()
+	cal, err := svc.GetCalendar(ctx, id)
+	if err != nil {
+		calendarError(c, err, "load calendar")
+		return nil, false
+	}
+	readable, err := calendarGroups(ctx, userID, "ro")
+	if err != nil {
+		calendarError(c, err, "check calendar access")
+		return nil, false
+	}
+	if !calendarGroupAllowed(readable, cal.GroupID) {
+		calendarError(c, service.ErrCalendarNotFound, "load calendar")
+		return nil, false
+	}
+	if permission == "rw" {
+		writable, err := calendarGroups(ctx, userID, "rw")
+		if err != nil {
+			calendarError(c, err, "check calendar access")
+			return nil, false
+		}
+		if !calendarGroupAllowed(writable, cal.GroupID) {
+			c.JSON(http.StatusForbidden, gin.H{"success": false, "error": "No write access to this calendar"})
+			return nil, false
+		}
+	}
+	return cal, true
+}
+
+// calendarAppointmentFor loads an appointment of the calendar in the path.
+func calendarAppointmentFor(c *gin.Context, svc *service.CalendarService, cal *models.Calendar) (*models.CalendarAppointment, bool) {
+	id, ok := calendarParam(c, "appointment_id", "appointment")
+	if !ok {
+		return nil, false
+	}
+	a, err := svc.GetAppointment(c.Request.Context(), id)
+	if err == nil && a.CalendarID != cal.ID {
+		err = service.ErrAppointmentNotFound
+	}
+	if err != nil {
+		calendarError(c, err, "load appointment")
+		return nil, false
+	}
+	return a, true
+}
+
+// readableCalendarIDs returns the valid calendars the agent can read.
+func readableCalendarIDs(ctx context.Context, svc *service.CalendarService, userID int) ([]int64, error) {
+	groups, err := calendarGroups(ctx, userID, "ro")
+	if err != nil {
+		return nil, err
+	}
+	calendars, err := svc.ListCalendars(ctx, groups, true)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]int64, 0, len(calendars))
+	for _, cal := range calendars {
+		ids = append(ids, cal.ID)
+	}
+	return ids, nil
+}
+
+// calendarBaseURL is the base URL used in feed links: app.base_url when
+// configured, else the scheme and host of the request.
+func calendarBaseURL(c *gin.Context) string {
+	if cfg := config.Get(); cfg != nil && cfg.App.BaseURL != "" {
+		return strings.TrimSuffix(cfg.App.BaseURL, "/")
+	}
+	return ssoBaseURL(c)
+}
+
+// HandleListCalendarsAPI handles GET /api/v1/calendars.
+//
+//	@Summary		List calendars
+//	@Description	Valid calendars of the groups the agent can read, each marked writable when the agent may change its appointments.
+//	@Tags			Calendars
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}	"Calendars"
+//	@Security		BearerAuth
+//	@Router			/calendars [get]
+func HandleListCalendarsAPI(c *gin.Context) {
+	userID, ok := changeAgentID(c)
+	if !ok {
+		return
+	}
+	svc := calendarService(c)
+	if svc == nil {
+		return
+	}
+	ctx := c.Request.Context()
+	readable, err := calendarGroups(ctx, userID, "ro")
+	if err != nil {
+		calendarError(c, err, "check calendar access")
+		return
+	}
+	writable, err := calendarGroups(ctx, userID, "rw")
+	if err != nil {
+		calendarError(c, err, "check calendar access")
+		return
+	}
+	calendars, err := svc.ListCalendars(ctx, readable, true)
+	if err != nil {
+		calendarError(c, err, "load calendars")
+		return
+	}
+	views := make([]calendarView, 0, len(calendars))
+	for _, cal := range calendars {
+		views = append(views, calendarView{Calendar: cal, Writable: calendarGroupAllowed(writable, cal.GroupID)})
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": views})
+}
+
+// HandleListCalendarAppointmentsAPI handles GET /api/v1/calendars/:id/appointments.
+//
+//	@Summary		List appointments
+//	@Description	Appointments of the calendar overlapping the range. The range defaults to the next 30 days and covers at most 366 days.
+//	@Tags			Calendars
+//	@Produce		json
+//	@Param			id		path		int		true	"Calendar ID"
+//	@Param			from	query		string	false	"Range start (RFC 3339 or YYYY-MM-DD, default now)"
+//	@Param			to		query		string	false	"Range end (RFC 3339 or YYYY-MM-DD, default from + 30 days)"
+//	@Success		200		{object}	map[string]interface{}	"Appointments"
+//	@Failure		400		{object}	map[string]interface{}	"Invalid range"
+//	@Failure		404		{object}	map[string]interface{}	"Calendar not found"
+//	@Security		BearerAuth
+//	@Router			/calendars/{id}/appointments [get]
+func HandleListCalendarAppointmentsAPI(c *gin.Context) {
+	id, ok := calendarParam(c, "id", "calendar")
+	if !ok {
+		return
+	}
+	from, to, ok := calendarRange(c)
+	if !ok {
+		return
+	}
+	userID, ok := changeAgentID(c)
+	if !ok {
+		return
+	}
+	svc := calendarService(c)
+	if svc == nil {
+		return
+	}
+	cal, ok := calendarFor(c, svc, userID, id, "ro")
+	if !ok {
+		return
+	}
+	appointments, err := svc.ListAppointments(c.Request.Context(), models.CalendarAppointmentFilter{
+		CalendarIDs: []int64{cal.ID}, From: from, To: to,
+	})
+	if err != nil {
+		calendarError(c, err, "load appointments")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": appointments, "from": from, "to": to})
+}
+
+// calendarRange parses the from/to query parameters, writing 400 when they
+// are invalid.
+func calendarRange(c *gin.Context) (time.Time, time.Time, bool) {
+	from := time.Now()
+	if v := c.Query("from"); v != "" {
+		t, err := parseChangeCalendarTime(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "from must be an RFC 3339 time or YYYY-MM-DD date"})
+			return time.Time{}, time.Time{}, false
+		}
+		from = t
+	}
+	to := from.AddDate(0, 0, 30)
+	if v := c.Query("to"); v != "" {
+		t, err := parseChangeCalendarTime(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "to must be an RFC 3339 time or YYYY-MM-DD date"})
+			return time.Time{}, time.Time{}, false
+		}
+		to = t
+	}
+	if !to.After(from) || to.Sub(from) > 366*24*time.Hour {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "to must be after from and at most 366 days later"})
+		return time.Time{}, time.Time{}, false
+	}
+	return from, to, true
+}
+
+// HandleGetCalendarAppointmentAPI handles GET /api/v1/calendars/:id/appointments/:appointment_id.
+//
+//	@Summary		Get appointment
+//	@Tags			Calendars
+//	@Produce		json
+//	@Param			id				path		int	true	"Calendar ID"
+//	@Param			appointment_id	path		int	true	"Appointment ID"
+//	@Success		200				{object}	map[string]interface{}	"Appointment"
+//	@Failure		404				{object}	map[string]interface{}	"Calendar or appointment not found"
+//	@Security		BearerAuth
+//	@Router			/calendars/{id}/appointments/{appointment_id} [get]
+func HandleGetCalendarAppointmentAPI(c *gin.Context) {
+	id, ok := calendarParam(c, "id", "calendar")
+	if !ok {
+		return
+	}
+	userID, ok := changeAgentID(c)
+	if !ok {
+		return
+	}
+	svc := calendarService(c)
+	if svc == nil {
+		return
+	}
+	cal, ok := calendarFor(c, svc, userID, id, "ro")
+	if !ok {
+		return
+	}
+	a, ok := calendarAppointmentFor(c, svc, cal)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": a})
+}
+
+// HandleCreateCalendarAppointmentAPI handles POST /api/v1/calendars/:id/appointments.
+//
+//	@Summary		Create appointment
+//	@Description	Requires rw permission on the calendar's group. Attendees must be valid agents; linked tickets must exist.
+//	@Tags			Calendars
+//	@Accept			json
+//	@Produce		json
+//	@Param			id			path		int		true	"Calendar ID"
+//	@Param			appointment	body		object	true	"Appointment (title, start_time, end_time, all_day, attendee_ids, ticket_ids, ...)"
+//	@Success		201			{object}	map[string]interface{}	"Appointment created"
+//	@Failure		400			{object}	map[string]interface{}	"Invalid request"
+//	@Failure		403			{object}	map[string]interface{}	"No write access"
+//	@Failure		404			{object}	map[string]interface{}	"Calendar not found"
+//	@Security		BearerAuth
+//	@Router			/calendars/{id}/appointments [post]
+func HandleCreateCalendarAppointmentAPI(c *gin.Context) {
+	id, ok := calendarParam(c, "id", "calendar")
+	if !ok {
+		return
+	}
+	var req appointmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid appointment request: " + err.Error()})
+		return
+	}
+	userID, ok := changeAgentID(c)
+	if !ok {
+		return
+	}
+	svc := calendarService(c)
+	if svc == nil {
+		return
+	}
+	if _, ok := calendarFor(c, svc, userID, id, "rw"); !ok {
+		return
+	}
+	a := req.appointment(id)
+	a.CalendarID = id
+	if err := svc.CreateAppointment(c.Request.Context(), a, userID); err != nil {
+		calendarError(c, err, "create appointment")
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"success": true, "data": a})
+}
+
+// HandleUpdateCalendarAppointmentAPI handles PUT /api/v1/calendars/:id/appointments/:appointment_id.
+//
+//	@Summary		Update appointment
+//	@Description	Replaces the appointment, including its attendees and ticket links. Setting calendar_id moves it to another calendar, which needs rw permission on both.
+//	@Tags			Calendars
+//	@Accept			json
+//	@Produce		json
+//	@Param			id				path		int		true	"Calendar ID"
+//	@Param			appointment_id	path		int		true	"Appointment ID"
+//	@Param			appointment		body		object	true	"Appointment"
+//	@Success		200				{object}	map[string]interface{}	"Appointment updated"
+//	@Failure		400				{object}	map[string]interface{}	"Invalid request"
+//	@Failure		403				{object}	map[string]interface{}	"No write access"
+//	@Failure		404				{object}	map[string]interface{}	"Calendar or appointment not found"
+//	@Security		BearerAuth
+//	@Router			/calendars/{id}/appointments/{appointment_id} [put]
+func HandleUpdateCalendarAppointmentAPI(c *gin.Context) {
+	id, ok := calendarParam(c, "id", "calendar")
+	if !ok {
+		return
+	}
+	if _, ok := calendarParam(c, "appointment_id", "appointment"); !ok {
+		return
+	}
+	var req appointmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid appointment request: " + err.Error()})
+		return
+	}
+	userID, ok := changeAgentID(c)
+	if !ok {
+		return
+	}
+	svc := calendarService(c)
+	if svc == nil {
+		return
+	}
+	cal, ok := calendarFor(c, svc, userID, id, "rw")
+	if !ok {
+		return
+	}
+	existing, ok := calendarAppointmentFor(c, svc, cal)
+	if !ok {
+		return
+	}
+	a := req.appointment(cal.ID)
+	a.ID = existing.ID
+	if a.CalendarID != cal.ID {
+		if _, ok := calendarFor(c, svc, userID, a.CalendarID, "rw"); !ok {
+			return
+		}
+	}
+	if err := svc.UpdateAppointment(c.Request.Context(), a, userID); err != nil {
+		calendarError(c, err, "update appointment")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": a})
+}
+
+// HandleDeleteCalendarAppointmentAPI handles DELETE /api/v1/calendars/:id/appointments/:appointment_id.
+//
+//	@Summary		Delete appointment
+//	@Tags			Calendars
+//	@Produce		json
+//	@Param			id				path		int	true	"Calendar ID"
+//	@Param			appointment_id	path		int	true	"Appointment ID"
+//	@Success		200				{object}	map[string]interface{}	"Appointment deleted"
+//	@Failure		403				{object}	map[string]interface{}	"No write access"
+//	@Failure		404				{object}	map[string]interface{}	"Calendar or appointment not found"
+//	@Security		BearerAuth
+//	@Router			/calendars/{id}/appointments/{appointment_id} [delete]
+func HandleDeleteCalendarAppointmentAPI(c *gin.Context) {
+	id, ok := calendarParam(c, "id", "calendar")
+	if !ok {
+		return
+	}
+	userID, ok := changeAgentID(c)
+	if !ok {
+		return
+	}
+	svc := calendarService(c)
+	if svc == nil {
+		return
+	}
+	cal, ok := calendarFor(c, svc, userID, id, "rw")
+	if !ok {
+		return
+	}
+	a, ok := calendarAppointmentFor(c, svc, cal)
+	if !ok {
+		return
+	}
+	if err := svc.DeleteAppointment(c.Request.Context(), a.ID); err != nil {
+		calendarError(c, err, "delete appointment")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// HandleGetCalendarFeedURLAPI handles GET /api/v1/calendars/:id/feed-url.
+//
+//	@Summary		Get iCal feed URL
+//	@Description	The agent's personal iCal feed of the calendar, for subscribing from calendar apps, and its CalDAV collection URL. Anyone with the feed URL can read the calendar until an admin resets its feed tokens.
+//	@Tags			Calendars
+//	@Produce		json
+//	@Param			id	path		int	true	"Calendar ID"
+//	@Success		200	{object}	map[string]interface{}	"Feed URLs"
+//	@Failure		404	{object}	map[string]interface{}	"Calendar not found"
+//	@Security		BearerAuth
+//	@Router			/calendars/{id}/feed-url [get]
+func HandleGetCalendarFeedURLAPI(c *gin.Context) {
+	id, ok := calendarParam(c, "id", "calendar")
+	if !ok {
+		return
+	}
+	userID, ok := changeAgentID(c)
+	if !ok {
+		return
+	}
+	svc := calendarService(c)
+	if svc == nil {
+		return
+	}
+	cal, ok := calendarFor(c, svc, userID, id, "ro")
+	if !ok {
+		return
+	}
+	token, err := svc.FeedToken(c.Request.Context(), cal, userID)
+	if err != nil {
+		calendarError(c, err, "create feed token")
+		return
+	}
+	base := calendarBaseURL(c)
+	query := url.Values{"user": {strconv.Itoa(userID)}, "token": {token}}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{
+		"ical_url":   fmt.Sprintf("%s/api/v1/calendars/%d/ical?%s", base, cal.ID, query.Encode()),
+		"caldav_url": fmt.Sprintf("%s/caldav/calendars/%d/", base, cal.ID),
+	}})
+}
+
+// HandleListTicketAppointmentsAPI handles GET /api/v1/tickets/:id/appointments.
+//
+//	@Summary		List ticket appointments
+//	@Description	Appointments linked to the ticket, in calendars the agent can read.
+//	@Tags			Calendars
+//	@Produce		json
+//	@Param			id	path		int	true	"Ticket ID"
+//	@Success		200	{object}	map[string]interface{}	"Appointments"
+//	@Security		BearerAuth
+//	@Router			/tickets/{id}/appointments [get]
+func HandleListTicketAppointmentsAPI(c *gin.Context) {
+	ticketID, ok := calendarParam(c, "id", "ticket")
+	if !ok {
+		return
+	}
+	userID, ok := changeAgentID(c)
+	if !ok {
+		return
+	}
+	svc := calendarService(c)
+	if svc == nil {
+		return
+	}
+	ctx := c.Request.Context()
+	calendarIDs, err := readableCalendarIDs(ctx, svc, userID)
+	if err != nil {
+		calendarError(c, err, "load calendars")
+		return
+	}
+	appointments, err := svc.ListAppointments(ctx, models.CalendarAppointmentFilter{CalendarIDs: calendarIDs, TicketID: ticketID})
+	if err != nil {
+		calendarError(c, err, "load appointments")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": appointments})
+}
+
+// HandleCalendarICalFeed handles GET /api/v1/calendars/:id/ical. It is
+// public: the feed token authenticates the agent, whose access to the
+// calendar is checked again on every request.
+//
+//	@Summary		iCal feed
+//	@Description	The calendar's appointments from 90 days ago to two years ahead as text/calendar. Get the URL from /calendars/{id}/feed-url.
+//	@Tags			Calendars
+//	@Produce		text/calendar
+//	@Param			id		path		int		true	"Calendar ID"
+//	@Param			user	query		int		true	"Agent ID"
+//	@Param			token	query		string	true	"Feed token"
+//	@Success		200		{string}	string	"iCalendar data"
+//	@Failure		404		{object}	map[string]interface{}	"Unknown calendar or invalid token"
+//	@Router			/calendars/{id}/ical [get]
+func HandleCalendarICalFeed(c *gin.Context) {
+	id, ok := calendarParam(c, "id", "calendar")
+	if !ok {
+		return
+	}
+	userID, err := strconv.Atoi(c.Query("user"))
+	if err != nil || userID <= 0 || c.Query("token") == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "user and token are required"})
+		return
+	}
+	svc := calendarService(c)
+	if svc == nil {
+		return
+	}
+	ctx := c.Request.Context()
+	cal, err := svc.GetCalendar(ctx, id)
+	if err != nil && !errors.Is(err, service.ErrCalendarNotFound) {
+		calendarError(c, err, "load calendar")
+		return
+	}
+	// Unknown calendars, bad tokens and lost access look the same
+	if cal == nil || cal.ValidID != 1 || !svc.VerifyFeedToken(cal, userID, c.Query("token")) {
+		calendarError(c, service.ErrCalendarNotFound, "load calendar")
+		return
+	}
+	valid, err := svc.ValidAgent(ctx, userID)
+	if err != nil {
+		calendarError(c, err, "check calendar access")
+		return
+	}
+	groups, err := calendarGroups(ctx, userID, "ro")
+	if err != nil {
+		calendarError(c, err, "check calendar access")
+		return
+	}
+	if !valid || !calendarGroupAllowed(groups, cal.GroupID) {
+		calendarError(c, service.ErrCalendarNotFound, "load calendar")
+		return
+	}
+	appointments, err := svc.FeedAppointments(ctx, cal)
+	if err != nil {
+		calendarError(c, err, "load appointments")
+		return
+	}
+	c.Header("Cache-Control", "private, max-age=300")
+	c.Header("Content-Disposition", fmt.Sprintf(`inline; filename="calendar-%d.ics"`, cal.ID))
+	c.Data(http.StatusOK, "text/calendar; charset=utf-8", service.RenderICalendar(cal.Name, appointments))
+}
+
+// HandleAdminListCalendarsAPI handles GET /api/v1/admin/calendars.
+//
+//	@Summary		List all calendars
+//	@Tags			Calendars
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}	"Calendars, including invalid ones"
+//	@Security		BearerAuth
+//	@Router			/admin/calendars [get]
+func HandleAdminListCalendarsAPI(c *gin.Context) {
+	svc := calendarService(c)
+	if svc == nil {
+		return
+	}
+	calendars, err := svc.ListCalendars(c.Request.Context(), nil, false)
+	if err != nil {
+		calendarError(c, err, "load calendars")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": calendars})
+}
+
+// HandleCreateCalendarAPI handles POST /api/v1/admin/calendars.
+//
+//	@Summary		Create calendar
+//	@Description	Agents with ro permission on the group see the calendar; rw permission lets them change its appointments.
+//	@Tags			Calendars
+//	@Accept			json
+//	@Produce		json
+//	@Param			calendar	body		object	true	"Calendar (name, group_id, color, valid_id)"
+//	@Success		201			{object}	map[string]interface{}	"Calendar created"
+//	@Failure		400			{object}	map[string]interface{}	"Invalid request"
+//	@Failure		409			{object}	map[string]interface{}	"Name already in use"
+//	@Security		BearerAuth
+//	@Router			/admin/calendars [post]
+func HandleCreateCalendarAPI(c *gin.Context) {
+	var req calendarRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid calendar request: " + err.Error()})
+		return
+	}
+	svc := calendarService(c)
+	if svc == nil {
+		return
+	}
+	cal := &models.Calendar{Name: req.Name, GroupID: req.GroupID, Color: req.Color, ValidID: req.ValidID}
+	if err := svc.CreateCalendar(c.Request.Context(), cal, GetUserIDFromCtx(c, 1)); err != nil {
+		calendarError(c, err, "create calendar")
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"success": true, "data": cal})
+}
+
+// HandleUpdateCalendarAPI handles PUT /api/v1/admin/calendars/:id.
+//
+//	@Summary		Update calendar
+//	@Tags			Calendars
+//	@Accept			json
+//	@Produce		json
+//	@Param			id			path		int		true	"Calendar ID"
+//	@Param			calendar	body		object	true	"Calendar"
+//	@Success		200			{object}	map[string]interface{}	"Calendar updated"
+//	@Failure		400			{object}	map[string]interface{}	"Invalid request"
+//	@Failure		404			{object}	map[string]interface{}	"Calendar not found"
+//	@Failure		409			{object}	map[string]interface{}	"Name already in use"
+//	@Security		BearerAuth
+//	@Router			/admin/calendars/{id} [put]
+func HandleUpdateCalendarAPI(c *gin.Context) {
+	id, ok := calendarParam(c, "id", "calendar")
+	if !ok {
+		return
+	}
+	var req calendarRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid calendar request: " + err.Error()})
+		return
+	}
+	svc := calendarService(c)
+	if svc == nil {
+		return
+	}
+	cal := &models.Calendar{ID: id, Name: req.Name, GroupID: req.GroupID, Color: req.Color, ValidID: req.ValidID}
+	if err := svc.UpdateCalendar(c.Request.Context(), cal, GetUserIDFromCtx(c, 1)); err != nil {
+		calendarError(c, err, "update calendar")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": cal})
+}
+
+// HandleDeleteCalendarAPI handles DELETE /api/v1/admin/calendars/:id.
+//
+//	@Summary		Delete calendar
+//	@Description	Deletes the calendar with all its appointments. Set valid_id to 2 instead to keep them.
+//	@Tags			Calendars
+//	@Produce		json
+//	@Param			id	path		int	true	"Calendar ID"
+//	@Success		200	{object}	map[string]interface{}	"Calendar deleted"
+//	@Failure		404	{object}	map[string]interface{}	"Calendar not found"
+//	@Security		BearerAuth
+//	@Router			/admin/calendars/{id} [delete]
+func HandleDeleteCalendarAPI(c *gin.Context) {
+	id, ok := calendarParam(c, "id", "calendar")
+	if !ok {
+		return
+	}
+	svc := calendarService(c)
+	if svc == nil {
+		return
+	}
+	if err := svc.DeleteCalendar(c.Request.Context(), id); err != nil {
+		calendarError(c, err, "delete calendar")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// HandleResetCalendarFeedTokensAPI handles POST /api/v1/admin/calendars/:id/reset-feed-tokens.
+//
+//	@Summary		Reset feed tokens
+//	@Description	Invalidates every iCal feed URL of the calendar. Agents fetch new URLs from /calendars/{id}/feed-url.
+//	@Tags			Calendars
+//	@Produce		json
+//	@Param			id	path		int	true	"Calendar ID"
+//	@Success		200	{object}	map[string]interface{}	"Feed tokens reset"
+//	@Failure		404	{object}	map[string]interface{}	"Calendar not found"
+//	@Security		BearerAuth
+//	@Router			/admin/calendars/{id}/reset-feed-tokens [post]
+func HandleResetCalendarFeedTokensAPI(c *gin.Context) {
+	id, ok := calendarParam(c, "id", "calendar")
+	if !ok {
+		return
+	}
+	svc := calendarService(c)
+	if svc == nil {
+		return
+	}
+	if err := svc.ResetFeedTokens(c.Request.Context(), id); err != nil {
+		calendarError(c, err, "reset feed tokens")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// handleDashboardAppointments renders the upcoming appointments widget:
+// the next seven days of the calendars the agent can read. Agents without
+// calendars get no widget.
+func handleDashboardAppointments(c *gin.Context) {
+	userID := GetUserIDFromCtx(c, 0)
+	db, err := database.GetDB()
+	if err != nil || db == nil || userID == 0 || kbIsCustomer(c) {
+		c.String(http.StatusOK, "")
+		return
+	}
+	svc := service.NewCalendarService(db)
+	ctx := c.Request.Context()
+	calendarIDs, err := readableCalendarIDs(ctx, svc, userID)
+	if err != nil || len(calendarIDs) == 0 {
+		if err != nil {
+			log.Printf("calendar api: load dashboard calendars failed: %v", err)
+		}
+		c.String(http.StatusOK, "")
+		return
+	}
+	calendars, err := svc.ListCalendars(ctx, nil, true)
+	if err != nil {
+		log.Printf("calendar api: load dashboard calendars failed: %v", err)
+		c.String(http.StatusOK, "")
+		return
+	}
+	colors := make(map[int64]string, len(calendars))
+	for _, cal := range calendars {
+		colors[cal.ID] = cal.Color
+	}
+	now := time.Now()
+	appointments, err := svc.ListAppointments(ctx, models.CalendarAppointmentFilter{
+		CalendarIDs: calendarIDs, From: now, To: now.AddDate(0, 0, 7), Limit: 10,
+	})
+	if err != nil {
+		log.Printf("calendar api: load dashboard appointments failed: %v", err)
+		c.String(http.StatusOK, "")
+		return
+	}
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(renderDashboardAppointments(appointments, colors)))
+}
+
+func renderDashboardAppointments(appointments []*models.CalendarAppointment, colors map[int64]string) string {
+	var b strings.Builder
+	b.WriteString(`<div class="mt-8 gk-card-glow" id="dashboard-appointments">
+    <div class="gk-card-header"><h3 class="gk-card-title">Upcoming appointments</h3></div>
+    <div class="gk-card-body">`)
+	if len(appointments) == 0 {
+		b.WriteString(`<p class="text-sm" style="color: var(--gk-text-muted);">No appointments in the next 7 days.</p>`)
+	} else {
+		b.WriteString(`<ul role="list" class="divide-y" style="border-color: var(--gk-border-default);">`)
+		for _, a := range appointments {
+			when := a.StartTime.Local().Format("Mon 02 Jan 15:04")
+			if a.AllDay {
+				when = a.StartTime.UTC().Format("Mon 02 Jan") + " (all day)"
+			}
+			fmt.Fprintf(&b, `
+        <li class="py-3 flex items-start gap-3">
+            <span class="mt-1.5 h-2.5 w-2.5 rounded-full flex-shrink-0" style="background: %s;"></span>
+            <div class="min-w-0 flex-1">
+                <p class="text-sm font-medium truncate" style="color: var(--gk-text-primary);">%s</p>
+                <p class="text-xs" style="color: var(--gk-text-muted);">%s</p>`,
+				html.EscapeString(colors[a.CalendarID]), html.EscapeString(a.Title), html.EscapeString(when))
+			for _, t := range a.Tickets {
+				fmt.Fprintf(&b, `
+                <a href="/tickets/%s" class="gk-link-neon text-xs">%s</a>`,
+					url.PathEscape(t.Number), html.EscapeString(t.Number))
+			}
+			b.WriteString(`
+            </div>
+        </li>`)
+		}
+		b.WriteString(`</ul>`)
+	}
+	b.WriteString(`</div></div>`)
+	return b.String()
+}
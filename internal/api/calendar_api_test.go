@@ -0,0 +1,147 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/models"
+)
+
+func TestCalendarHandlers_InvalidRequest(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("user_id", 1)
+		c.Next()
+	})
+	router.GET("/api/v1/calendars/:id/appointments", HandleListCalendarAppointmentsAPI)
+	router.POST("/api/v1/calendars/:id/appointments", HandleCreateCalendarAppointmentAPI)
+	router.PUT("/api/v1/calendars/:id/appointments/:appointment_id", HandleUpdateCalendarAppointmentAPI)
+	router.GET("/api/v1/calendars/:id/ical", HandleCalendarICalFeed)
+	router.GET("/api/v1/tickets/:id/appointments", HandleListTicketAppointmentsAPI)
+	router.POST("/api/v1/admin/calendars", HandleCreateCalendarAPI)
+	router.PUT("/api/v1/admin/calendars/:id", HandleUpdateCalendarAPI)
+	router.DELETE("/api/v1/admin/calendars/:id", HandleDeleteCalendarAPI)
+
+	for _, tc := range []struct {
+		method string
+		path   string
+		body   string
+		want   string
+	}{
+		{http.MethodGet, "/api/v1/calendars/x/appointments", "", "Invalid calendar ID"},
+		{http.MethodGet, "/api/v1/calendars/1/appointments?from=soon", "", "from must be"},
+		{http.MethodGet, "/api/v1/calendars/1/appointments?from=2026-03-10&to=2026-03-01", "", "to must be after from"},
+		{http.MethodGet, "/api/v1/calendars/1/appointments?from=2026-01-01&to=2027-06-01", "", "at most 366 days"},
+		{http.MethodPost, "/api/v1/calendars/1/appointments", `{"start_time": "2026-03-10T09:00:00Z", "end_time": "2026-03-10T10:00:00Z"}`, "Invalid appointment request"},
+		{http.MethodPost, "/api/v1/calendars/1/appointments", `{"title": "Review", "start_time": "tomorrow"}`, "Invalid appointment request"},
+		{http.MethodPut, "/api/v1/calendars/1/appointments/0", `{}`, "Invalid appointment ID"},
+		{http.MethodGet, "/api/v1/calendars/1/ical?token=abc", "", "user and token are required"},
+		{http.MethodGet, "/api/v1/calendars/1/ical?user=1", "", "user and token are required"},
+		{http.MethodGet, "/api/v1/tickets/-2/appointments", "", "Invalid ticket ID"},
+		{http.MethodPost, "/api/v1/admin/calendars", `{"name": "Support"}`, "Invalid calendar request"},
+		{http.MethodPut, "/api/v1/admin/calendars/1", `{"group_id": 1}`, "Invalid calendar request"},
+		{http.MethodDelete, "/api/v1/admin/calendars/abc", "", "Invalid calendar ID"},
+	} {
+		t.Run(tc.method+" "+tc.path+" "+tc.body, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Contains(t, w.Body.String(), tc.want)
+		})
+	}
+}
+
+func TestCalendarHandlers_CustomersForbidden(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("user_id", 5)
+		c.Set("user_role", "Customer")
+		c.Next()
+	})
+	router.GET("/api/v1/calendars", HandleListCalendarsAPI)
+	router.Handle("PROPFIND", "/caldav/calendars/", HandleCalDAVHomePropfind)
+
+	for _, tc := range []struct{ method, path string }{
+		{http.MethodGet, "/api/v1/calendars"},
+		{"PROPFIND", "/caldav/calendars/"},
+	} {
+		req := httptest.NewRequest(tc.method, tc.path, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusForbidden, w.Code, tc.path)
+	}
+}
+
+func TestCalendarGroupAllowed(t *testing.T) {
+	assert.True(t, calendarGroupAllowed(nil, 3), "admins may use every calendar")
+	assert.True(t, calendarGroupAllowed([]int{1, 3}, 3))
+	assert.False(t, calendarGroupAllowed([]int{1, 2}, 3))
+	assert.False(t, calendarGroupAllowed([]int{}, 3))
+}
+
+func TestParseCalDAVReport(t *testing.T) {
+	report, err := parseCalDAVReport(strings.NewReader(`<?xml version="1.0" encoding="utf-8" ?>
+<C:calendar-query xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav">
+  <D:prop><D:getetag/></D:prop>
+  <C:filter>
+    <C:comp-filter name="VCALENDAR">
+      <C:comp-filter name="VEVENT">
+        <C:time-range start="20260301T000000Z" end="20260401T000000Z"/>
+      </C:comp-filter>
+    </C:comp-filter>
+  </C:filter>
+</C:calendar-query>`))
+	require.NoError(t, err)
+	assert.Equal(t, "calendar-query", report.kind)
+	assert.Equal(t, time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), report.from)
+	assert.Equal(t, time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC), report.to)
+
+	report, err = parseCalDAVReport(strings.NewReader(`<C:calendar-multiget xmlns:D="DAV:" xmlns:C="urn:ietf:params:xml:ns:caldav">
+  <D:prop><D:getetag/><C:calendar-data/></D:prop>
+  <D:href>/caldav/calendars/1/7.ics</D:href>
+  <D:href>/caldav/calendars/1/9.ics</D:href>
+</C:calendar-multiget>`))
+	require.NoError(t, err)
+	assert.Equal(t, "calendar-multiget", report.kind)
+	assert.Equal(t, []string{"/caldav/calendars/1/7.ics", "/caldav/calendars/1/9.ics"}, report.hrefs)
+
+	_, err = parseCalDAVReport(strings.NewReader(`<D:sync-collection xmlns:D="DAV:"/>`))
+	assert.Error(t, err)
+	_, err = parseCalDAVReport(strings.NewReader(`<C:calendar-query xmlns:C="urn:ietf:params:xml:ns:caldav"><C:time-range start="soon"/></C:calendar-query>`))
+	assert.Error(t, err)
+}
+
+func TestCalDAVAppointmentID(t *testing.T) {
+	id, ok := caldavAppointmentID("42.ics")
+	assert.True(t, ok)
+	assert.Equal(t, int64(42), id)
+	_, ok = caldavAppointmentID("uid-42.ics")
+	assert.False(t, ok)
+}
+
+func TestRenderDashboardAppointments(t *testing.T) {
+	start := time.Date(2026, 3, 10, 9, 0, 0, 0, time.UTC)
+	out := renderDashboardAppointments([]*models.CalendarAppointment{{
+		ID: 1, CalendarID: 2, Title: "<b>Review</b>", StartTime: start, EndTime: start.Add(time.Hour),
+		Tickets: []models.CalendarAppointmentTicket{{ID: 3, Number: "2026031010000003"}},
+	}}, map[int64]string{2: "#3A87AD"})
+	assert.Contains(t, out, "&lt;b&gt;Review&lt;/b&gt;")
+	assert.Contains(t, out, `href="/tickets/2026031010000003"`)
+	assert.Contains(t, out, "#3A87AD")
+
+	assert.Contains(t, renderDashboardAppointments(nil, nil), "No appointments in the next 7 days")
+}
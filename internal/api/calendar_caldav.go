@@ -0,0 +1,430 @@
+package api
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/service"
+)
+
+// Read-only CalDAV (RFC 4791) access to the calendars an agent can read.
+// Clients sign in with Basic auth, using an API token as the password.
+// /caldav/calendars/ is both the agent's principal and calendar home; each
+// calendar is a collection of <appointment id>.ics resources.
+
+const (
+	caldavHome       = "/caldav/calendars/"
+	caldavMaxRequest = 1 << 20
+)
+
+// caldavReportRequest holds what GoatFlow uses of a REPORT body.
+type caldavReportRequest struct {
+	kind  string // calendar-query or calendar-multiget
+	hrefs []string
+	from  time.Time
+	to    time.Time
+}
+
+// parseCalDAVReport reads a calendar-query or calendar-multiget REPORT.
+// Of the filters of a calendar-query only the time range is applied; any
+// other filter returns the whole calendar, which clients filter again.
+func parseCalDAVReport(body io.Reader) (*caldavReportRequest, error) {
+	dec := xml.NewDecoder(io.LimitReader(body, caldavMaxRequest))
+	report := &caldavReportRequest{}
+	inHref := false
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if report.kind == "" {
+				report.kind = t.Name.Local
+			}
+			switch t.Name.Local {
+			case "href":
+				inHref = true
+			case "time-range":
+				for _, attr := range t.Attr {
+					value, err := service.ParseICalendarTime(attr.Value)
+					if err != nil {
+						return nil, fmt.Errorf("invalid time-range %s: %w", attr.Name.Local, err)
+					}
+					switch attr.Name.Local {
+					case "start":
+						report.from = value
+					case "end":
+						report.to = value
+					}
+				}
+			}
+		case xml.EndElement:
+			inHref = false
+		case xml.CharData:
+			if inHref {
+				report.hrefs = append(report.hrefs, strings.TrimSpace(string(t)))
+			}
+		}
+	}
+	if report.kind != "calendar-query" && report.kind != "calendar-multiget" {
+		return nil, fmt.Errorf("unsupported report %q", report.kind)
+	}
+	return report, nil
+}
+
+// caldavMultistatus builds a 207 Multi-Status body.
+type caldavMultistatus struct {
+	b strings.Builder
+}
+
+func newCalDAVMultistatus() *caldavMultistatus {
+	m := &caldavMultistatus{}
+	m.b.WriteString(`<?xml version="1.0" encoding="utf-8"?>` + "\n" +
+		`<d:multistatus xmlns:d="DAV:" xmlns:c="urn:ietf:params:xml:ns:caldav" ` +
+		`xmlns:cs="http://calendarserver.org/ns/" xmlns:ic="http://apple.com/ns/ical/">`)
+	return m
+}
+
+// found adds a resource with its properties, which must be valid XML.
+func (m *caldavMultistatus) found(href, props string) {
+	fmt.Fprintf(&m.b, `<d:response><d:href>%s</d:href><d:propstat><d:prop>%s</d:prop>`+
+		`<d:status>HTTP/1.1 200 OK</d:status></d:propstat></d:response>`, xmlText(href), props)
+}
+
+// missing adds a resource that does not exist.
+func (m *caldavMultistatus) missing(href string) {
+	fmt.Fprintf(&m.b, `<d:response><d:href>%s</d:href><d:status>HTTP/1.1 404 Not Found</d:status></d:response>`, xmlText(href))
+}
+
+func (m *caldavMultistatus) write(c *gin.Context) {
+	m.b.WriteString(`</d:multistatus>`)
+	c.Data(http.StatusMultiStatus, "application/xml; charset=utf-8", []byte(m.b.String()))
+}
+
+func xmlText(s string) string {
+	var b strings.Builder
+	_ = xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
+
+// caldavDepth returns the Depth header: 0 or 1, with infinity treated as 1.
+func caldavDepth(c *gin.Context) int {
+	if c.GetHeader("Depth") == "0" {
+		return 0
+	}
+	return 1
+}
+
+func caldavCalendarHref(cal *models.Calendar) string {
+	return fmt.Sprintf("%s%d/", caldavHome, cal.ID)
+}
+
+func caldavEventHref(a *models.CalendarAppointment) string {
+	return fmt.Sprintf("%s%d/%d.ics", caldavHome, a.CalendarID, a.ID)
+}
+
+func caldavETag(a *models.CalendarAppointment) string {
+	return fmt.Sprintf(`"%d-%d"`, a.ID, a.ChangeTime.Unix())
+}
+
+// caldavCTag changes whenever an appointment of the calendar is added,
+// changed or removed.
+func caldavCTag(appointments []*models.CalendarAppointment) string {
+	var latest int64
+	for _, a := range appointments {
+		if t := a.ChangeTime.Unix(); t > latest {
+			latest = t
+		}
+	}
+	return fmt.Sprintf("%d-%d", len(appointments), latest)
+}
+
+func caldavHomeProps() string {
+	return `<d:resourcetype><d:collection/></d:resourcetype>` +
+		`<d:displayname>GoatFlow</d:displayname>` +
+		`<d:current-user-principal><d:href>` + caldavHome + `</d:href></d:current-user-principal>` +
+		`<d:principal-URL><d:href>` + caldavHome + `</d:href></d:principal-URL>` +
+		`<c:calendar-home-set><d:href>` + caldavHome + `</d:href></c:calendar-home-set>`
+}
+
+func caldavCalendarProps(cal *models.Calendar, appointments []*models.CalendarAppointment) string {
+	return `<d:resourcetype><d:collection/><c:calendar/></d:resourcetype>` +
+		`<d:displayname>` + xmlText(cal.Name) + `</d:displayname>` +
+		`<ic:calendar-color>` + xmlText(cal.Color) + `</ic:calendar-color>` +
+		`<c:supported-calendar-component-set><c:comp name="VEVENT"/></c:supported-calendar-component-set>` +
+		`<d:current-user-privilege-set><d:privilege><d:read/></d:privilege></d:current-user-privilege-set>` +
+		`<cs:getctag>` + caldavCTag(appointments) + `</cs:getctag>`
+}
+
+func caldavEventProps(a *models.CalendarAppointment, withData bool, calendarName string) string {
+	props := `<d:resourcetype/>` +
+		`<d:getcontenttype>text/calendar; charset=utf-8; component=VEVENT</d:getcontenttype>` +
+		`<d:getetag>` + xmlText(caldavETag(a)) + `</d:getetag>` +
+		`<d:getlastmodified>` + a.ChangeTime.UTC().Format(http.TimeFormat) + `</d:getlastmodified>`
+	if withData {
+		props += `<c:calendar-data>` + xmlText(string(service.RenderICalendar(calendarName, []*models.CalendarAppointment{a}))) + `</c:calendar-data>`
+	}
+	return props
+}
+
+// caldavAppointmentID parses an :event path parameter such as "42.ics".
+func caldavAppointmentID(event string) (int64, bool) {
+	id, err := strconv.ParseInt(strings.TrimSuffix(event, ".ics"), 10, 64)
+	return id, err == nil && id > 0
+}
+
+// caldavCalendar loads the readable calendar of the :id path parameter.
+func caldavCalendar(c *gin.Context, svc *service.CalendarService, userID int) (*models.Calendar, bool) {
+	id, ok := calendarParam(c, "id", "calendar")
+	if !ok {
+		return nil, false
+	}
+	cal, ok := calendarFor(c, svc, userID, id, "ro")
+	if !ok {
+		return nil, false
+	}
+	if cal.ValidID != 1 {
+		calendarError(c, service.ErrCalendarNotFound, "load calendar")
+		return nil, false
+	}
+	return cal, true
+}
+
+// caldavAppointment loads the appointment of the :event path parameter.
+func caldavAppointment(c *gin.Context, svc *service.CalendarService, cal *models.Calendar) (*models.CalendarAppointment, bool) {
+	id, ok := caldavAppointmentID(c.Param("event"))
+	if !ok {
+		calendarError(c, service.ErrAppointmentNotFound, "load appointment")
+		return nil, false
+	}
+	a, err := svc.GetAppointment(c.Request.Context(), id)
+	if err == nil && a.CalendarID != cal.ID {
+		err = service.ErrAppointmentNotFound
+	}
+	if err != nil {
+		calendarError(c, err, "load appointment")
+		return nil, false
+	}
+	return a, true
+}
+
+// HandleCalDAVOptions handles OPTIONS on the CalDAV resources.
+func HandleCalDAVOptions(c *gin.Context) {
+	c.Header("DAV", "1, calendar-access")
+	c.Header("Allow", "OPTIONS, GET, PROPFIND, REPORT")
+	c.Status(http.StatusOK)
+}
+
+// HandleCalDAVHomePropfind handles PROPFIND /caldav/calendars/: the
+// principal and calendar home, and with Depth 1 the calendars the agent
+// can read.
+func HandleCalDAVHomePropfind(c *gin.Context) {
+	userID, ok := changeAgentID(c)
+	if !ok {
+		return
+	}
+	svc := calendarService(c)
+	if svc == nil {
+		return
+	}
+	ms := newCalDAVMultistatus()
+	ms.found(caldavHome, caldavHomeProps())
+	if caldavDepth(c) > 0 {
+		ctx := c.Request.Context()
+		groups, err := calendarGroups(ctx, userID, "ro")
+		if err != nil {
+			calendarError(c, err, "check calendar access")
+			return
+		}
+		calendars, err := svc.ListCalendars(ctx, groups, true)
+		if err != nil {
+			calendarError(c, err, "load calendars")
+			return
+		}
+		for _, cal := range calendars {
+			appointments, err := svc.FeedAppointments(ctx, cal)
+			if err != nil {
+				calendarError(c, err, "load appointments")
+				return
+			}
+			ms.found(caldavCalendarHref(cal), caldavCalendarProps(cal, appointments))
+		}
+	}
+	ms.write(c)
+}
+
+// HandleCalDAVCalendarPropfind handles PROPFIND /caldav/calendars/:id/:
+// the calendar, and with Depth 1 its appointments from 90 days ago to two
+// years ahead.
+func HandleCalDAVCalendarPropfind(c *gin.Context) {
+	userID, ok := changeAgentID(c)
+	if !ok {
+		return
+	}
+	svc := calendarService(c)
+	if svc == nil {
+		return
+	}
+	cal, ok := caldavCalendar(c, svc, userID)
+	if !ok {
+		return
+	}
+	appointments, err := svc.FeedAppointments(c.Request.Context(), cal)
+	if err != nil {
+		calendarError(c, err, "load appointments")
+		return
+	}
+	ms := newCalDAVMultistatus()
+	ms.found(caldavCalendarHref(cal), caldavCalendarProps(cal, appointments))
+	if caldavDepth(c) > 0 {
+		for _, a := range appointments {
+			ms.found(caldavEventHref(a), caldavEventProps(a, false, cal.Name))
+		}
+	}
+	ms.write(c)
+}
+
+// HandleCalDAVEventPropfind handles PROPFIND /caldav/calendars/:id/:event.
+func HandleCalDAVEventPropfind(c *gin.Context) {
+	userID, ok := changeAgentID(c)
+	if !ok {
+		return
+	}
+	svc := calendarService(c)
+	if svc == nil {
+		return
+	}
+	cal, ok := caldavCalendar(c, svc, userID)
+	if !ok {
+		return
+	}
+	a, ok := caldavAppointment(c, svc, cal)
+	if !ok {
+		return
+	}
+	ms := newCalDAVMultistatus()
+	ms.found(caldavEventHref(a), caldavEventProps(a, false, cal.Name))
+	ms.write(c)
+}
+
+// HandleCalDAVReport handles REPORT /caldav/calendars/:id/ with a
+// calendar-query or calendar-multiget body.
+func HandleCalDAVReport(c *gin.Context) {
+	userID, ok := changeAgentID(c)
+	if !ok {
+		return
+	}
+	report, err := parseCalDAVReport(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid REPORT request: " + err.Error()})
+		return
+	}
+	svc := calendarService(c)
+	if svc == nil {
+		return
+	}
+	cal, ok := caldavCalendar(c, svc, userID)
+	if !ok {
+		return
+	}
+	ctx := c.Request.Context()
+	ms := newCalDAVMultistatus()
+
+	if report.kind == "calendar-multiget" {
+		for _, href := range report.hrefs {
+			id, ok := caldavAppointmentID(href[strings.LastIndex(href, "/")+1:])
+			if !ok || !strings.HasPrefix(href, caldavCalendarHref(cal)) {
+				ms.missing(href)
+				continue
+			}
+			a, err := svc.GetAppointment(ctx, id)
+			if errors.Is(err, service.ErrAppointmentNotFound) || (err == nil && a.CalendarID != cal.ID) {
+				ms.missing(href)
+				continue
+			}
+			if err != nil {
+				calendarError(c, err, "load appointment")
+				return
+			}
+			ms.found(caldavEventHref(a), caldavEventProps(a, true, cal.Name))
+		}
+		ms.write(c)
+		return
+	}
+
+	var appointments []*models.CalendarAppointment
+	if report.from.IsZero() && report.to.IsZero() {
+		appointments, err = svc.FeedAppointments(ctx, cal)
+	} else {
+		appointments, err = svc.ListAppointments(ctx, models.CalendarAppointmentFilter{
+			CalendarIDs: []int64{cal.ID}, From: report.from, To: report.to,
+		})
+	}
+	if err != nil {
+		calendarError(c, err, "load appointments")
+		return
+	}
+	for _, a := range appointments {
+		ms.found(caldavEventHref(a), caldavEventProps(a, true, cal.Name))
+	}
+	ms.write(c)
+}
+
+// HandleCalDAVCalendarGet handles GET /caldav/calendars/:id/: the whole
+// calendar as one iCalendar object, like the iCal feed.
+func HandleCalDAVCalendarGet(c *gin.Context) {
+	userID, ok := changeAgentID(c)
+	if !ok {
+		return
+	}
+	svc := calendarService(c)
+	if svc == nil {
+		return
+	}
+	cal, ok := caldavCalendar(c, svc, userID)
+	if !ok {
+		return
+	}
+	appointments, err := svc.FeedAppointments(c.Request.Context(), cal)
+	if err != nil {
+		calendarError(c, err, "load appointments")
+		return
+	}
+	c.Data(http.StatusOK, "text/calendar; charset=utf-8", service.RenderICalendar(cal.Name, appointments))
+}
+
+// HandleCalDAVEventGet handles GET /caldav/calendars/:id/:event.
+func HandleCalDAVEventGet(c *gin.Context) {
+	userID, ok := changeAgentID(c)
+	if !ok {
+		return
+	}
+	svc := calendarService(c)
+	if svc == nil {
+		return
+	}
+	cal, ok := caldavCalendar(c, svc, userID)
+	if !ok {
+		return
+	}
+	a, ok := caldavAppointment(c, svc, cal)
+	if !ok {
+		return
+	}
+	c.Header("ETag", caldavETag(a))
+	c.Header("Last-Modified", a.ChangeTime.UTC().Format(http.TimeFormat))
+	c.Data(http.StatusOK, "text/calendar; charset=utf-8", service.RenderICalendar(cal.Name, []*models.CalendarAppointment{a}))
+}
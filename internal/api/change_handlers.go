@@ -0,0 +1,337 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/service"
+)
+
+// changeReadableQueues returns the queues the agent can read, or nil when
+// the agent can read every queue. Tests replace it to avoid a database.
+var changeReadableQueues = func(ctx context.Context, userID int) ([]int, error) {
+	db, err := database.GetDB()
+	if err != nil || db == nil {
+		return nil, err
+	}
+	access := service.NewQueueAccessService(db)
+	if admin, err := access.IsAdmin(ctx, uint(userID)); err != nil || admin {
+		return nil, err
+	}
+	ids, err := access.GetAccessibleQueueIDs(ctx, uint(userID), "ro")
+	if err != nil {
+		return nil, err
+	}
+	queues := make([]int, 0, len(ids))
+	for _, id := range ids {
+		queues = append(queues, int(id))
+	}
+	return queues, nil
+}
+
+// changePlanRequest is the JSON body accepted by the change plan handler.
+type changePlanRequest struct {
+	PlannedStart       time.Time         `json:"planned_start"`
+	PlannedEnd         time.Time         `json:"planned_end"`
+	Risk               models.ChangeRisk `json:"risk"`
+	ImplementationPlan string            `json:"implementation_plan"`
+	BackoutPlan        string            `json:"backout_plan"`
+	ServiceIDs         []int             `json:"service_ids"`
+	ConfigItems        []string          `json:"config_items"`
+}
+
+func changeService(c *gin.Context) *service.ChangeService {
+	db, err := database.GetDB()
+	if err != nil || db == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"success": false, "error": "Database unavailable"})
+		return nil
+	}
+	return service.NewChangeService(db)
+}
+
+// changeWriteError maps ChangeService errors to responses.
+func changeWriteError(c *gin.Context, err error, action string) {
+	switch {
+	case errors.Is(err, service.ErrChangeNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Ticket has no change plan"})
+	case errors.Is(err, service.ErrChangeTicketNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Ticket not found"})
+	case errors.Is(err, service.ErrChangeWindowRequired),
+		errors.Is(err, service.ErrChangeInvalidWindow),
+		errors.Is(err, service.ErrChangeInvalidRisk),
+		errors.Is(err, service.ErrChangeInvalidService),
+		errors.Is(err, service.ErrChangeInvalidItem),
+		errors.Is(err, service.ErrChangeInvalidCalendar):
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+	default:
+		log.Printf("change api: %s failed: %v", action, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to " + action})
+	}
+}
+
+// changeAgentID returns the calling agent, writing 403 for customers and 401
+// when nobody is signed in.
+func changeAgentID(c *gin.Context) (int, bool) {
+	if kbIsCustomer(c) {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": "Agent access required"})
+		return 0, false
+	}
+	userID := GetUserIDFromCtx(c, 0)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"success": false, "error": "Authentication required"})
+		return 0, false
+	}
+	return userID, true
+}
+
+// redactChangeConflicts blanks the title of conflicting changes in queues
+// the agent cannot read; the ticket number and window stay so that the
+// collision can still be raised with the queue's owners.
+func redactChangeConflicts(plans []models.ChangePlan, queues []int) {
+	if queues == nil {
+		return
+	}
+	readable := make(map[int]bool, len(queues))
+	for _, id := range queues {
+		readable[id] = true
+	}
+	for i := range plans {
+		for j := range plans[i].Conflicts {
+			if !readable[plans[i].Conflicts[j].QueueID] {
+				plans[i].Conflicts[j].Title = ""
+			}
+		}
+	}
+}
+
+// changeConflictWarnings describes a plan's conflicts for people.
+func changeConflictWarnings(plan *models.ChangePlan) []string {
+	warnings := make([]string, 0, len(plan.Conflicts))
+	for _, conflict := range plan.Conflicts {
+		shared := make([]string, 0, len(conflict.ServiceIDs)+len(conflict.ConfigItems))
+		for _, id := range conflict.ServiceIDs {
+			shared = append(shared, fmt.Sprintf("service %d", id))
+		}
+		shared = append(shared, conflict.ConfigItems...)
+		warnings = append(warnings, fmt.Sprintf("Change %s touches %s between %s and %s",
+			conflict.TicketNumber, strings.Join(shared, ", "),
+			conflict.PlannedStart.UTC().Format(time.RFC3339), conflict.PlannedEnd.UTC().Format(time.RFC3339)))
+	}
+	return warnings
+}
+
+// writeChangePlan writes a plan after redacting conflicts the agent may not
+// see.
+func writeChangePlan(c *gin.Context, status int, userID int, plan *models.ChangePlan) {
+	queues, err := changeReadableQueues(c.Request.Context(), userID)
+	if err != nil {
+		changeWriteError(c, err, "check queue access")
+		return
+	}
+	plans := []models.ChangePlan{*plan}
+	redactChangeConflicts(plans, queues)
+	c.JSON(status, gin.H{"success": true, "data": plans[0], "warnings": changeConflictWarnings(&plans[0])})
+}
+
+// HandleGetTicketChangeAPI handles GET /api/v1/tickets/:id/change.
+//
+//	@Summary		Get change plan
+//	@Description	Returns the planned window, risk and impacted services and configuration items of a change ticket, with the open changes it collides with.
+//	@Tags			Changes
+//	@Produce		json
+//	@Param			id	path		int	true	"Ticket ID"
+//	@Success		200	{object}	map[string]interface{}	"Change plan"
+//	@Failure		404	{object}	map[string]interface{}	"Ticket has no change plan"
+//	@Security		BearerAuth
+//	@Router			/tickets/{id}/change [get]
+func HandleGetTicketChangeAPI(c *gin.Context) {
+	ticketID, ok := approvalID(c, "ticket")
+	if !ok {
+		return
+	}
+	userID, ok := changeAgentID(c)
+	if !ok {
+		return
+	}
+	svc := changeService(c)
+	if svc == nil {
+		return
+	}
+	plan, err := svc.Get(c.Request.Context(), ticketID)
+	if err != nil {
+		changeWriteError(c, err, "load change plan")
+		return
+	}
+	writeChangePlan(c, http.StatusOK, userID, plan)
+}
+
+// HandleSaveTicketChangeAPI handles PUT /api/v1/tickets/:id/change.
+//
+//	@Summary		Plan change
+//	@Description	Makes the ticket a change ticket or replaces its plan. Collisions with other open changes touching the same services or configuration items in an overlapping window do not block the plan; they are returned as warnings. Approval before closing follows the ticket's queue approval rules.
+//	@Tags			Changes
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int		true	"Ticket ID"
+//	@Param			plan	body		object	true	"Change plan (planned_start, planned_end, risk, implementation_plan, backout_plan, service_ids, config_items)"
+//	@Success		200		{object}	map[string]interface{}	"Change planned"
+//	@Failure		400		{object}	map[string]interface{}	"Invalid request"
+//	@Failure		404		{object}	map[string]interface{}	"Ticket not found"
+//	@Security		BearerAuth
+//	@Router			/tickets/{id}/change [put]
+func HandleSaveTicketChangeAPI(c *gin.Context) {
+	ticketID, ok := approvalID(c, "ticket")
+	if !ok {
+		return
+	}
+	userID, ok := changeAgentID(c)
+	if !ok {
+		return
+	}
+	var req changePlanRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid change plan: " + err.Error()})
+		return
+	}
+	svc := changeService(c)
+	if svc == nil {
+		return
+	}
+
+	plan, err := svc.Save(c.Request.Context(), &models.ChangePlan{
+		TicketID:           ticketID,
+		PlannedStart:       req.PlannedStart,
+		PlannedEnd:         req.PlannedEnd,
+		Risk:               req.Risk,
+		ImplementationPlan: req.ImplementationPlan,
+		BackoutPlan:        req.BackoutPlan,
+		ServiceIDs:         req.ServiceIDs,
+		ConfigItems:        req.ConfigItems,
+	}, userID)
+	if err != nil {
+		changeWriteError(c, err, "save change plan")
+		return
+	}
+	writeChangePlan(c, http.StatusOK, userID, plan)
+}
+
+// HandleDeleteTicketChangeAPI handles DELETE /api/v1/tickets/:id/change.
+//
+//	@Summary		Remove change plan
+//	@Description	Removes the plan; the ticket itself is kept.
+//	@Tags			Changes
+//	@Produce		json
+//	@Param			id	path		int	true	"Ticket ID"
+//	@Success		200	{object}	map[string]interface{}	"Change plan removed"
+//	@Failure		404	{object}	map[string]interface{}	"Ticket has no change plan"
+//	@Security		BearerAuth
+//	@Router			/tickets/{id}/change [delete]
+func HandleDeleteTicketChangeAPI(c *gin.Context) {
+	ticketID, ok := approvalID(c, "ticket")
+	if !ok {
+		return
+	}
+	userID, ok := changeAgentID(c)
+	if !ok {
+		return
+	}
+	svc := changeService(c)
+	if svc == nil {
+		return
+	}
+	if err := svc.Delete(c.Request.Context(), ticketID, userID); err != nil {
+		changeWriteError(c, err, "remove change plan")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Change plan removed"})
+}
+
+// parseChangeCalendarTime accepts RFC 3339 timestamps and plain dates.
+func parseChangeCalendarTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", value)
+}
+
+// HandleChangeCalendarAPI handles GET /api/v1/changes/calendar.
+//
+//	@Summary		Change calendar
+//	@Description	Lists changes whose planned window overlaps the range, in queues the agent can read, each with the open changes it collides with. The range defaults to the next 30 days and covers at most 366 days.
+//	@Tags			Changes
+//	@Produce		json
+//	@Param			from		query		string	false	"Range start (RFC 3339 or YYYY-MM-DD, default now)"
+//	@Param			to			query		string	false	"Range end (RFC 3339 or YYYY-MM-DD, default from + 30 days)"
+//	@Param			service_id	query		int		false	"Only changes touching this service"
+//	@Param			ci			query		string	false	"Only changes touching this configuration item"
+//	@Success		200			{object}	map[string]interface{}	"Changes"
+//	@Failure		400			{object}	map[string]interface{}	"Invalid range"
+//	@Security		BearerAuth
+//	@Router			/changes/calendar [get]
+func HandleChangeCalendarAPI(c *gin.Context) {
+	userID, ok := changeAgentID(c)
+	if !ok {
+		return
+	}
+
+	filter := models.ChangeCalendarFilter{From: time.Now(), ConfigItem: c.Query("ci")}
+	if v := c.Query("from"); v != "" {
+		t, err := parseChangeCalendarTime(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "from must be an RFC 3339 time or YYYY-MM-DD date"})
+			return
+		}
+		filter.From = t
+	}
+	filter.To = filter.From.AddDate(0, 0, 30)
+	if v := c.Query("to"); v != "" {
+		t, err := parseChangeCalendarTime(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "to must be an RFC 3339 time or YYYY-MM-DD date"})
+			return
+		}
+		filter.To = t
+	}
+	if v := c.Query("service_id"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil || id <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid service ID"})
+			return
+		}
+		filter.ServiceID = id
+	}
+
+	queues, err := changeReadableQueues(c.Request.Context(), userID)
+	if err != nil {
+		changeWriteError(c, err, "check queue access")
+		return
+	}
+	filter.QueueIDs = queues
+
+	svc := changeService(c)
+	if svc == nil {
+		return
+	}
+	plans, err := svc.Calendar(c.Request.Context(), filter)
+	if err != nil {
+		changeWriteError(c, err, "load change calendar")
+		return
+	}
+	redactChangeConflicts(plans, queues)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    plans,
+		"from":    filter.From,
+		"to":      filter.To,
+	})
+}
@@ -0,0 +1,67 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/goatkit/goatflow/internal/models"
+)
+
+func TestChangeHandlers_InvalidInput(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("user_id", uint(5))
+		c.Next()
+	})
+	router.GET("/api/v1/tickets/:id/change", HandleGetTicketChangeAPI)
+	router.DELETE("/api/v1/tickets/:id/change", HandleDeleteTicketChangeAPI)
+	router.GET("/api/v1/changes/calendar", HandleChangeCalendarAPI)
+
+	for _, r := range []struct{ method, path, want string }{
+		{http.MethodGet, "/api/v1/tickets/abc/change", "Invalid ticket ID"},
+		{http.MethodDelete, "/api/v1/tickets/0/change", "Invalid ticket ID"},
+		{http.MethodGet, "/api/v1/changes/calendar?from=tomorrow", "from must be"},
+		{http.MethodGet, "/api/v1/changes/calendar?to=2026-13-01", "to must be"},
+		{http.MethodGet, "/api/v1/changes/calendar?service_id=x", "Invalid service ID"},
+	} {
+		req := httptest.NewRequest(r.method, r.path, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, r.path)
+		assert.Contains(t, w.Body.String(), r.want, r.path)
+	}
+}
+
+func TestRedactChangeConflicts(t *testing.T) {
+	start := time.Date(2026, 11, 2, 20, 0, 0, 0, time.UTC)
+	plans := []models.ChangePlan{{
+		TicketID: 1,
+		Conflicts: []models.ChangeConflict{
+			{TicketID: 2, TicketNumber: "1002", Title: "Patch database", QueueID: 2,
+				PlannedStart: start, PlannedEnd: start.Add(time.Hour), ConfigItems: []string{"db01"}},
+			{TicketID: 3, TicketNumber: "1003", Title: "Mail upgrade", QueueID: 1,
+				PlannedStart: start, PlannedEnd: start.Add(time.Hour), ServiceIDs: []int{4}},
+		},
+	}}
+
+	redactChangeConflicts(plans, nil)
+	assert.Equal(t, "Patch database", plans[0].Conflicts[0].Title, "agents who read every queue see every title")
+
+	redactChangeConflicts(plans, []int{1})
+	assert.Empty(t, plans[0].Conflicts[0].Title)
+	assert.Equal(t, "1002", plans[0].Conflicts[0].TicketNumber)
+	assert.Equal(t, "Mail upgrade", plans[0].Conflicts[1].Title)
+
+	assert.Equal(t, []string{
+		"Change 1002 touches db01 between 2026-11-02T20:00:00Z and 2026-11-02T21:00:00Z",
+		"Change 1003 touches service 4 between 2026-11-02T20:00:00Z and 2026-11-02T21:00:00Z",
+	}, changeConflictWarnings(&plans[0]))
+}
@@ -0,0 +1,102 @@
+package api
+
+import (
+	"database/sql"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/events"
+	"github.com/goatkit/goatflow/internal/models"
+)
+
+// The ticket zoom sends the newest article it rendered as seen_article_id.
+// If other agents have replied since, or are composing right now, the
+// submission is answered with 409 so the agent can review before sending a
+// duplicate response. Resubmitting with confirm_concurrent=1 goes through.
+const (
+	seenArticleField       = "seen_article_id"
+	confirmConcurrentField = "confirm_concurrent"
+)
+
+// concurrentArticle is an article added to the ticket after the form opened.
+type concurrentArticle struct {
+	ID      int    `json:"id"`
+	From    string `json:"from"`
+	Subject string `json:"subject"`
+}
+
+// lastArticleID returns the highest article ID, the value the zoom page
+// submits as seen_article_id.
+func lastArticleID(articles []models.Article) int {
+	last := 0
+	for _, a := range articles {
+		if a.ID > last {
+			last = a.ID
+		}
+	}
+	return last
+}
+
+// checkConcurrentReply reports whether the reply may proceed. Otherwise it
+// writes a 409 listing the new articles and the agents still composing.
+// Requests without seen_article_id (API clients) are never held back.
+func checkConcurrentReply(c *gin.Context, db *sql.DB, ticketID, userID int) bool {
+	seen, err := strconv.Atoi(strings.TrimSpace(c.PostForm(seenArticleField)))
+	if err != nil || seen <= 0 || c.PostForm(confirmConcurrentField) == "1" {
+		return true
+	}
+
+	articles, err := articlesSince(c, db, ticketID, seen, userID)
+	if err != nil {
+		log.Printf("Concurrent reply check failed for ticket %d: %v", ticketID, err)
+		return true
+	}
+	composing := make([]string, 0)
+	for _, v := range events.DefaultPresence().Viewers(ticketID) {
+		if v.Composing && v.UserID != userID {
+			composing = append(composing, v.Name)
+		}
+	}
+	if len(articles) == 0 && len(composing) == 0 {
+		return true
+	}
+
+	c.JSON(http.StatusConflict, gin.H{
+		"success":   false,
+		"conflict":  true,
+		"error":     "The ticket changed while you were writing",
+		"articles":  articles,
+		"composing": composing,
+	})
+	return false
+}
+
+// articlesSince lists articles newer than seen that were not written by the
+// current agent.
+func articlesSince(c *gin.Context, db *sql.DB, ticketID, seen, userID int) ([]concurrentArticle, error) {
+	rows, err := db.QueryContext(c.Request.Context(), database.ConvertPlaceholders(`
+		SELECT a.id, COALESCE(adm.a_from, ''), COALESCE(adm.a_subject, '')
+		FROM article a
+		LEFT JOIN article_data_mime adm ON a.id = adm.article_id
+		WHERE a.ticket_id = ? AND a.id > ? AND a.create_by <> ?
+		ORDER BY a.id`), ticketID, seen, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	articles := make([]concurrentArticle, 0)
+	for rows.Next() {
+		var a concurrentArticle
+		if err := rows.Scan(&a.ID, &a.From, &a.Subject); err != nil {
+			return nil, err
+		}
+		articles = append(articles, a)
+	}
+	return articles, rows.Err()
+}
@@ -0,0 +1,90 @@
+package api
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/events"
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/testutil"
+)
+
+func newConcurrentReplyDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db := testutil.MigratedDB(t)
+	// Ticket 5 has articles 10-12 by agents 1, 2 and 7; ticket 6 has 13.
+	for _, a := range []struct{ id, ticketID, by int }{{10, 5, 1}, {11, 5, 2}, {12, 5, 7}, {13, 6, 2}} {
+		_, err := db.Exec(`INSERT INTO article (id, ticket_id, article_sender_type_id, communication_channel_id,
+			is_visible_for_customer, create_time, create_by, change_time, change_by)
+			VALUES (?, ?, 1, 1, 1, CURRENT_TIMESTAMP, ?, CURRENT_TIMESTAMP, ?)`, a.id, a.ticketID, a.by, a.by)
+		require.NoError(t, err)
+	}
+	_, err := db.Exec(`INSERT INTO article_data_mime (article_id, a_from, a_subject, incoming_time,
+		create_time, create_by, change_time, change_by)
+		VALUES (11, 'Bob Agent', 'Re: printer', 0, CURRENT_TIMESTAMP, 2, CURRENT_TIMESTAMP, 2)`)
+	require.NoError(t, err)
+	return db
+}
+
+func concurrentReplyContext(form url.Values) (*gin.Context, *httptest.ResponseRecorder) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/agent/tickets/5/note", strings.NewReader(form.Encode()))
+	c.Request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return c, w
+}
+
+func TestCheckConcurrentReply(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := newConcurrentReplyDB(t)
+
+	t.Run("newer article from another agent", func(t *testing.T) {
+		c, w := concurrentReplyContext(url.Values{"seen_article_id": {"10"}})
+		assert.False(t, checkConcurrentReply(c, db, 5, 7))
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Contains(t, w.Body.String(), `"conflict":true`)
+		assert.Contains(t, w.Body.String(), "Re: printer")
+		assert.NotContains(t, w.Body.String(), `"id":12`, "own articles are not conflicts")
+	})
+
+	t.Run("confirmed", func(t *testing.T) {
+		c, _ := concurrentReplyContext(url.Values{"seen_article_id": {"10"}, "confirm_concurrent": {"1"}})
+		assert.True(t, checkConcurrentReply(c, db, 5, 7))
+	})
+
+	t.Run("up to date", func(t *testing.T) {
+		c, _ := concurrentReplyContext(url.Values{"seen_article_id": {"12"}})
+		assert.True(t, checkConcurrentReply(c, db, 5, 7))
+	})
+
+	t.Run("clients without seen_article_id", func(t *testing.T) {
+		c, _ := concurrentReplyContext(url.Values{"body": {"hi"}})
+		assert.True(t, checkConcurrentReply(c, db, 5, 7))
+	})
+
+	t.Run("another agent composing", func(t *testing.T) {
+		events.DefaultPresence().Touch(5, 0, 2, "Bob Agent", true)
+		events.DefaultPresence().Touch(5, 0, 7, "Me", true)
+		t.Cleanup(func() {
+			events.DefaultPresence().Leave(5, 2)
+			events.DefaultPresence().Leave(5, 7)
+		})
+
+		c, w := concurrentReplyContext(url.Values{"seen_article_id": {"12"}})
+		assert.False(t, checkConcurrentReply(c, db, 5, 7))
+		assert.Contains(t, w.Body.String(), `"composing":["Bob Agent"]`)
+	})
+}
+
+func TestLastArticleID(t *testing.T) {
+	assert.Equal(t, 0, lastArticleID(nil))
+	assert.Equal(t, 12, lastArticleID([]models.Article{{ID: 3}, {ID: 12}, {ID: 7}}))
+}
@@ -0,0 +1,549 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/service"
+)
+
+// configItemClassRequest is the JSON body accepted by the admin class
+// create/update handlers.
+type configItemClassRequest struct {
+	Name        string                       `json:"name" binding:"required"`
+	Description string                       `json:"description"`
+	Attributes  []models.ConfigItemAttribute `json:"attributes"`
+	ValidID     int                          `json:"valid_id"`
+}
+
+// configItemRequest is the JSON body accepted by the config item
+// create/update handlers.
+type configItemRequest struct {
+	Number         string                 `json:"number"`
+	ClassID        int                    `json:"class_id" binding:"required"`
+	Name           string                 `json:"name" binding:"required"`
+	State          string                 `json:"state"`
+	CustomerID     string                 `json:"customer_id"`
+	CustomerUserID string                 `json:"customer_user_id"`
+	Attributes     map[string]interface{} `json:"attributes"`
+}
+
+func (r *configItemRequest) item() *models.ConfigItem {
+	return &models.ConfigItem{
+		Number:         r.Number,
+		ClassID:        r.ClassID,
+		Name:           r.Name,
+		State:          r.State,
+		CustomerID:     r.CustomerID,
+		CustomerUserID: r.CustomerUserID,
+		Attributes:     r.Attributes,
+	}
+}
+
+// configItemService returns the service, writing 503 when the database is
+// unavailable.
+func configItemService(c *gin.Context) *service.ConfigItemService {
+	db, err := database.GetDB()
+	if err != nil || db == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"success": false, "error": "Database unavailable"})
+		return nil
+	}
+	return service.NewConfigItemService(db)
+}
+
+// configItemError maps ConfigItemService errors to responses.
+func configItemError(c *gin.Context, err error, action string) {
+	switch {
+	case errors.Is(err, service.ErrConfigItemClassNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Config item class not found"})
+	case errors.Is(err, service.ErrConfigItemNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Config item not found"})
+	case errors.Is(err, service.ErrConfigItemTicketNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Ticket not found"})
+	case errors.Is(err, service.ErrConfigItemLinkNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": err.Error()})
+	case errors.Is(err, service.ErrConfigItemClassNameExists), errors.Is(err, service.ErrConfigItemNumberExists),
+		errors.Is(err, service.ErrConfigItemClassInUse):
+		c.JSON(http.StatusConflict, gin.H{"success": false, "error": err.Error()})
+	case errors.Is(err, service.ErrConfigItemClassInvalid), errors.Is(err, service.ErrConfigItemInvalid):
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+	default:
+		log.Printf("config item api: %s failed: %v", action, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to " + action})
+	}
+}
+
+// configItemParam parses an ID path parameter, writing 400 when it is
+// invalid.
+func configItemParam(c *gin.Context, name, what string) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param(name), 10, 64)
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid " + what + " ID"})
+		return 0, false
+	}
+	return id, true
+}
+
+// configItemFilter parses the search query parameters, writing 400 when
+// they are invalid.
+func configItemFilter(c *gin.Context) (models.ConfigItemFilter, bool) {
+	filter := models.ConfigItemFilter{
+		State:          c.Query("state"),
+		CustomerID:     c.Query("customer_id"),
+		CustomerUserID: c.Query("customer_user_id"),
+		Query:          c.Query("q"),
+	}
+	for _, p := range []struct {
+		name string
+		set  func(int64)
+	}{
+		{"class_id", func(v int64) { filter.ClassID = int(v) }},
+		{"ticket_id", func(v int64) { filter.TicketID = v }},
+		{"limit", func(v int64) { filter.Limit = int(v) }},
+		{"offset", func(v int64) { filter.Offset = int(v) }},
+	} {
+		raw := c.Query(p.name)
+		if raw == "" {
+			continue
+		}
+		v, err := strconv.ParseInt(raw, 10, 32)
+		if err != nil || v < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": p.name + " must be a non-negative integer"})
+			return filter, false
+		}
+		p.set(v)
+	}
+	return filter, true
+}
+
+// HandleListConfigItemClassesAPI handles GET /api/v1/config-item-classes.
+//
+//	@Summary		List config item classes
+//	@Description	Valid classes with the attributes their items carry.
+//	@Tags			CMDB
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}	"Classes"
+//	@Security		BearerAuth
+//	@Router			/config-item-classes [get]
+func HandleListConfigItemClassesAPI(c *gin.Context) {
+	if _, ok := changeAgentID(c); !ok {
+		return
+	}
+	svc := configItemService(c)
+	if svc == nil {
+		return
+	}
+	classes, err := svc.ListClasses(c.Request.Context(), true)
+	if err != nil {
+		configItemError(c, err, "load config item classes")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": classes})
+}
+
+// HandleSearchConfigItemsAPI handles GET /api/v1/config-items.
+//
+//	@Summary		Search config items
+//	@Description	Items ordered by name. q matches the number, name and attribute values.
+//	@Tags			CMDB
+//	@Produce		json
+//	@Param			q					query		string	false	"Substring of the number, name or attribute values"
+//	@Param			class_id			query		int		false	"Class"
+//	@Param			state				query		string	false	"Lifecycle state"
+//	@Param			customer_id			query		string	false	"Customer company"
+//	@Param			customer_user_id	query		string	false	"Customer user login"
+//	@Param			ticket_id			query		int		false	"Items linked to the ticket"
+//	@Param			limit				query		int		false	"Page size (default 50, max 500)"
+//	@Param			offset				query		int		false	"Items to skip"
+//	@Success		200					{object}	map[string]interface{}	"Items and total"
+//	@Failure		400					{object}	map[string]interface{}	"Invalid filter"
+//	@Security		BearerAuth
+//	@Router			/config-items [get]
+func HandleSearchConfigItemsAPI(c *gin.Context) {
+	filter, ok := configItemFilter(c)
+	if !ok {
+		return
+	}
+	if _, ok := changeAgentID(c); !ok {
+		return
+	}
+	svc := configItemService(c)
+	if svc == nil {
+		return
+	}
+	items, total, err := svc.SearchItems(c.Request.Context(), filter)
+	if err != nil {
+		configItemError(c, err, "search config items")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": items, "total": total})
+}
+
+// HandleGetConfigItemAPI handles GET /api/v1/config-items/:id.
+//
+//	@Summary		Get config item
+//	@Description	The item with its linked tickets.
+//	@Tags			CMDB
+//	@Produce		json
+//	@Param			id	path		int	true	"Config item ID"
+//	@Success		200	{object}	map[string]interface{}	"Config item"
+//	@Failure		404	{object}	map[string]interface{}	"Config item not found"
+//	@Security		BearerAuth
+//	@Router			/config-items/{id} [get]
+func HandleGetConfigItemAPI(c *gin.Context) {
+	id, ok := configItemParam(c, "id", "config item")
+	if !ok {
+		return
+	}
+	if _, ok := changeAgentID(c); !ok {
+		return
+	}
+	svc := configItemService(c)
+	if svc == nil {
+		return
+	}
+	item, err := svc.GetItem(c.Request.Context(), id)
+	if err != nil {
+		configItemError(c, err, "load config item")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": item})
+}
+
+// HandleCreateConfigItemAPI handles POST /api/v1/config-items.
+//
+//	@Summary		Create config item
+//	@Description	Items start planned unless they set a state; items without a number are numbered CI-000001 and up.
+//	@Tags			CMDB
+//	@Accept			json
+//	@Produce		json
+//	@Param			item	body		object	true	"Config item (class_id, name, number, state, customer_id, customer_user_id, attributes)"
+//	@Success		201		{object}	map[string]interface{}	"Config item created"
+//	@Failure		400		{object}	map[string]interface{}	"Invalid request"
+//	@Failure		404		{object}	map[string]interface{}	"Class not found"
+//	@Failure		409		{object}	map[string]interface{}	"Number already in use"
+//	@Security		BearerAuth
+//	@Router			/config-items [post]
+func HandleCreateConfigItemAPI(c *gin.Context) {
+	var req configItemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid config item request: " + err.Error()})
+		return
+	}
+	userID, ok := changeAgentID(c)
+	if !ok {
+		return
+	}
+	svc := configItemService(c)
+	if svc == nil {
+		return
+	}
+	item := req.item()
+	if err := svc.CreateItem(c.Request.Context(), item, userID); err != nil {
+		configItemError(c, err, "create config item")
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"success": true, "data": item})
+}
+
+// HandleUpdateConfigItemAPI handles PUT /api/v1/config-items/:id.
+//
+//	@Summary		Update config item
+//	@Description	Replaces the item's fields and attributes. The state may only move along the lifecycle; retired items stay retired.
+//	@Tags			CMDB
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int		true	"Config item ID"
+//	@Param			item	body		object	true	"Config item"
+//	@Success		200		{object}	map[string]interface{}	"Config item updated"
+//	@Failure		400		{object}	map[string]interface{}	"Invalid request or state change"
+//	@Failure		404		{object}	map[string]interface{}	"Config item not found"
+//	@Failure		409		{object}	map[string]interface{}	"Number already in use"
+//	@Security		BearerAuth
+//	@Router			/config-items/{id} [put]
+func HandleUpdateConfigItemAPI(c *gin.Context) {
+	id, ok := configItemParam(c, "id", "config item")
+	if !ok {
+		return
+	}
+	var req configItemRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid config item request: " + err.Error()})
+		return
+	}
+	userID, ok := changeAgentID(c)
+	if !ok {
+		return
+	}
+	svc := configItemService(c)
+	if svc == nil {
+		return
+	}
+	item := req.item()
+	item.ID = id
+	if err := svc.UpdateItem(c.Request.Context(), item, userID); err != nil {
+		configItemError(c, err, "update config item")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": item})
+}
+
+// HandleDeleteConfigItemAPI handles DELETE /api/v1/config-items/:id.
+//
+//	@Summary		Delete config item
+//	@Description	Deletes the item and its ticket links. Items at the end of their life are usually retired instead.
+//	@Tags			CMDB
+//	@Produce		json
+//	@Param			id	path		int	true	"Config item ID"
+//	@Success		200	{object}	map[string]interface{}	"Config item deleted"
+//	@Failure		404	{object}	map[string]interface{}	"Config item not found"
+//	@Security		BearerAuth
+//	@Router			/config-items/{id} [delete]
+func HandleDeleteConfigItemAPI(c *gin.Context) {
+	id, ok := configItemParam(c, "id", "config item")
+	if !ok {
+		return
+	}
+	svc := configItemService(c)
+	if svc == nil {
+		return
+	}
+	if err := svc.DeleteItem(c.Request.Context(), id); err != nil {
+		configItemError(c, err, "delete config item")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// HandleListTicketConfigItemsAPI handles GET /api/v1/tickets/:id/config-items.
+//
+//	@Summary		List config items of a ticket
+//	@Tags			CMDB
+//	@Produce		json
+//	@Param			id	path		int	true	"Ticket ID"
+//	@Success		200	{object}	map[string]interface{}	"Config items"
+//	@Security		BearerAuth
+//	@Router			/tickets/{id}/config-items [get]
+func HandleListTicketConfigItemsAPI(c *gin.Context) {
+	ticketID, ok := configItemParam(c, "id", "ticket")
+	if !ok {
+		return
+	}
+	if _, ok := changeAgentID(c); !ok {
+		return
+	}
+	svc := configItemService(c)
+	if svc == nil {
+		return
+	}
+	items, err := svc.TicketItems(c.Request.Context(), ticketID)
+	if err != nil {
+		configItemError(c, err, "load ticket config items")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": items})
+}
+
+// HandleLinkTicketConfigItemAPI handles POST /api/v1/tickets/:id/config-items.
+//
+//	@Summary		Link config item to ticket
+//	@Description	Records an affected asset on the ticket. Retired items cannot be linked.
+//	@Tags			CMDB
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int		true	"Ticket ID"
+//	@Param			link	body		object	true	"Link (config_item_id)"
+//	@Success		200		{object}	map[string]interface{}	"Already linked"
+//	@Success		201		{object}	map[string]interface{}	"Linked"
+//	@Failure		400		{object}	map[string]interface{}	"Invalid request"
+//	@Failure		404		{object}	map[string]interface{}	"Ticket or config item not found"
+//	@Security		BearerAuth
+//	@Router			/tickets/{id}/config-items [post]
+func HandleLinkTicketConfigItemAPI(c *gin.Context) {
+	ticketID, ok := configItemParam(c, "id", "ticket")
+	if !ok {
+		return
+	}
+	var req struct {
+		ConfigItemID int64 `json:"config_item_id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || req.ConfigItemID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "config_item_id is required"})
+		return
+	}
+	userID, ok := changeAgentID(c)
+	if !ok {
+		return
+	}
+	svc := configItemService(c)
+	if svc == nil {
+		return
+	}
+	linked, err := svc.LinkTicket(c.Request.Context(), req.ConfigItemID, ticketID, userID)
+	if err != nil {
+		configItemError(c, err, "link config item")
+		return
+	}
+	status := http.StatusOK
+	if linked {
+		status = http.StatusCreated
+	}
+	c.JSON(status, gin.H{"success": true, "linked": linked})
+}
+
+// HandleUnlinkTicketConfigItemAPI handles DELETE /api/v1/tickets/:id/config-items/:config_item_id.
+//
+//	@Summary		Unlink config item from ticket
+//	@Tags			CMDB
+//	@Produce		json
+//	@Param			id				path		int	true	"Ticket ID"
+//	@Param			config_item_id	path		int	true	"Config item ID"
+//	@Success		200				{object}	map[string]interface{}	"Unlinked"
+//	@Failure		404				{object}	map[string]interface{}	"Not linked"
+//	@Security		BearerAuth
+//	@Router			/tickets/{id}/config-items/{config_item_id} [delete]
+func HandleUnlinkTicketConfigItemAPI(c *gin.Context) {
+	ticketID, ok := configItemParam(c, "id", "ticket")
+	if !ok {
+		return
+	}
+	itemID, ok := configItemParam(c, "config_item_id", "config item")
+	if !ok {
+		return
+	}
+	if _, ok := changeAgentID(c); !ok {
+		return
+	}
+	svc := configItemService(c)
+	if svc == nil {
+		return
+	}
+	if err := svc.UnlinkTicket(c.Request.Context(), itemID, ticketID); err != nil {
+		configItemError(c, err, "unlink config item")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// HandleAdminListConfigItemClassesAPI handles GET /api/v1/admin/config-item-classes.
+//
+//	@Summary		List all config item classes
+//	@Tags			CMDB
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}	"Classes, including invalid ones"
+//	@Security		BearerAuth
+//	@Router			/admin/config-item-classes [get]
+func HandleAdminListConfigItemClassesAPI(c *gin.Context) {
+	svc := configItemService(c)
+	if svc == nil {
+		return
+	}
+	classes, err := svc.ListClasses(c.Request.Context(), false)
+	if err != nil {
+		configItemError(c, err, "load config item classes")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": classes})
+}
+
+// HandleCreateConfigItemClassAPI handles POST /api/v1/admin/config-item-classes.
+//
+//	@Summary		Create config item class
+//	@Description	Attributes have a key, label, type (text, number, date, bool or select), required flag and, for select, options.
+//	@Tags			CMDB
+//	@Accept			json
+//	@Produce		json
+//	@Param			class	body		object	true	"Class (name, description, attributes, valid_id)"
+//	@Success		201		{object}	map[string]interface{}	"Class created"
+//	@Failure		400		{object}	map[string]interface{}	"Invalid request"
+//	@Failure		409		{object}	map[string]interface{}	"Name already in use"
+//	@Security		BearerAuth
+//	@Router			/admin/config-item-classes [post]
+func HandleCreateConfigItemClassAPI(c *gin.Context) {
+	var req configItemClassRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid config item class request: " + err.Error()})
+		return
+	}
+	svc := configItemService(c)
+	if svc == nil {
+		return
+	}
+	class := &models.ConfigItemClass{Name: req.Name, Description: req.Description, Attributes: req.Attributes, ValidID: req.ValidID}
+	if err := svc.CreateClass(c.Request.Context(), class, GetUserIDFromCtx(c, 1)); err != nil {
+		configItemError(c, err, "create config item class")
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"success": true, "data": class})
+}
+
+// HandleUpdateConfigItemClassAPI handles PUT /api/v1/admin/config-item-classes/:id.
+//
+//	@Summary		Update config item class
+//	@Description	Replaces the class and its attributes. Values of removed attributes are dropped from items when they are next updated.
+//	@Tags			CMDB
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int		true	"Class ID"
+//	@Param			class	body		object	true	"Class"
+//	@Success		200		{object}	map[string]interface{}	"Class updated"
+//	@Failure		400		{object}	map[string]interface{}	"Invalid request"
+//	@Failure		404		{object}	map[string]interface{}	"Class not found"
+//	@Failure		409		{object}	map[string]interface{}	"Name already in use"
+//	@Security		BearerAuth
+//	@Router			/admin/config-item-classes/{id} [put]
+func HandleUpdateConfigItemClassAPI(c *gin.Context) {
+	id, ok := configItemParam(c, "id", "config item class")
+	if !ok {
+		return
+	}
+	var req configItemClassRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid config item class request: " + err.Error()})
+		return
+	}
+	svc := configItemService(c)
+	if svc == nil {
+		return
+	}
+	class := &models.ConfigItemClass{ID: int(id), Name: req.Name, Description: req.Description, Attributes: req.Attributes, ValidID: req.ValidID}
+	if err := svc.UpdateClass(c.Request.Context(), class, GetUserIDFromCtx(c, 1)); err != nil {
+		configItemError(c, err, "update config item class")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": class})
+}
+
+// HandleDeleteConfigItemClassAPI handles DELETE /api/v1/admin/config-item-classes/:id.
+//
+//	@Summary		Delete config item class
+//	@Description	Only classes without items can be deleted; set valid_id to 2 to retire a class in use.
+//	@Tags			CMDB
+//	@Produce		json
+//	@Param			id	path		int	true	"Class ID"
+//	@Success		200	{object}	map[string]interface{}	"Class deleted"
+//	@Failure		404	{object}	map[string]interface{}	"Class not found"
+//	@Failure		409	{object}	map[string]interface{}	"Class still has items"
+//	@Security		BearerAuth
+//	@Router			/admin/config-item-classes/{id} [delete]
+func HandleDeleteConfigItemClassAPI(c *gin.Context) {
+	id, ok := configItemParam(c, "id", "config item class")
+	if !ok {
+		return
+	}
+	svc := configItemService(c)
+	if svc == nil {
+		return
+	}
+	if err := svc.DeleteClass(c.Request.Context(), int(id)); err != nil {
+		configItemError(c, err, "delete config item class")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
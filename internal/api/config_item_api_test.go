@@ -0,0 +1,78 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigItemHandlers_InvalidRequest(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("user_id", 1)
+		c.Next()
+	})
+	router.GET("/api/v1/config-items", HandleSearchConfigItemsAPI)
+	router.GET("/api/v1/config-items/:id", HandleGetConfigItemAPI)
+	router.POST("/api/v1/config-items", HandleCreateConfigItemAPI)
+	router.PUT("/api/v1/config-items/:id", HandleUpdateConfigItemAPI)
+	router.POST("/api/v1/tickets/:id/config-items", HandleLinkTicketConfigItemAPI)
+	router.DELETE("/api/v1/tickets/:id/config-items/:config_item_id", HandleUnlinkTicketConfigItemAPI)
+	router.POST("/api/v1/admin/config-item-classes", HandleCreateConfigItemClassAPI)
+	router.DELETE("/api/v1/admin/config-item-classes/:id", HandleDeleteConfigItemClassAPI)
+
+	for _, tc := range []struct {
+		method string
+		path   string
+		body   string
+		want   string
+	}{
+		{http.MethodGet, "/api/v1/config-items?class_id=laptop", "", "class_id must be a non-negative integer"},
+		{http.MethodGet, "/api/v1/config-items?limit=-1", "", "limit must be a non-negative integer"},
+		{http.MethodGet, "/api/v1/config-items/x", "", "Invalid config item ID"},
+		{http.MethodPost, "/api/v1/config-items", `{"name": "Laptop"}`, "Invalid config item request"},
+		{http.MethodPut, "/api/v1/config-items/0", `{}`, "Invalid config item ID"},
+		{http.MethodPut, "/api/v1/config-items/1", `{"class_id": 1}`, "Invalid config item request"},
+		{http.MethodPost, "/api/v1/tickets/1/config-items", `{}`, "config_item_id is required"},
+		{http.MethodPost, "/api/v1/tickets/abc/config-items", `{"config_item_id": 1}`, "Invalid ticket ID"},
+		{http.MethodDelete, "/api/v1/tickets/1/config-items/-3", "", "Invalid config item ID"},
+		{http.MethodPost, "/api/v1/admin/config-item-classes", `{"description": "no name"}`, "Invalid config item class request"},
+		{http.MethodDelete, "/api/v1/admin/config-item-classes/abc", "", "Invalid config item class ID"},
+	} {
+		t.Run(tc.method+" "+tc.path+" "+tc.body, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Contains(t, w.Body.String(), tc.want)
+		})
+	}
+}
+
+func TestConfigItemHandlers_CustomersForbidden(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("user_id", 5)
+		c.Set("user_role", "Customer")
+		c.Next()
+	})
+	router.GET("/api/v1/config-items", HandleSearchConfigItemsAPI)
+	router.GET("/api/v1/config-item-classes", HandleListConfigItemClassesAPI)
+
+	for _, path := range []string{"/api/v1/config-items", "/api/v1/config-item-classes"} {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusForbidden, w.Code, path)
+	}
+}
@@ -13,6 +13,8 @@ import (
 	"github.com/gin-gonic/gin"
 
 	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/service"
+	"github.com/goatkit/goatflow/internal/storage"
 )
 
 // verifyCustomerOwnsTicket checks if the authenticated customer owns the specified ticket.
@@ -30,10 +32,11 @@ func verifyCustomerOwnsTicket(c *gin.Context, db *sql.DB, ticketIDStr, username
 	}
 
 	// Verify customer owns this ticket
+	queueFilter, queueArgs := portalQueueFilter(c, "queue_id")
 	var exists bool
 	err = db.QueryRow(database.ConvertPlaceholders(`
-		SELECT EXISTS(SELECT 1 FROM ticket WHERE id = ? AND customer_user_id = ?)
-	`), ticketID, username).Scan(&exists)
+		SELECT EXISTS(SELECT 1 FROM ticket WHERE id = ? AND customer_user_id = ?`+queueFilter+`)
+	`), append([]interface{}{ticketID, username}, queueArgs...)...).Scan(&exists)
 
 	if err != nil || !exists {
 		c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
@@ -185,20 +188,29 @@ func handleCustomerUploadAttachment(db *sql.DB) gin.HandlerFunc {
 		}
 
 		// Process attachments using the shared helper
-		processFormAttachments(files, attachmentProcessParams{
+		rejected := processFormAttachments(files, attachmentProcessParams{
 			ctx:       context.Background(),
 			db:        db,
 			ticketID:  ticketID,
 			articleID: articleID,
 			userID:    systemUserID,
+			role:      service.AttachmentRoleCustomer,
 		})
+		if len(rejected) == len(files) {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": strings.Join(rejected, "; ")})
+			return
+		}
 
 		// Return success with HTMX trigger
 		c.Header("HX-Trigger", "attachments-updated")
-		c.JSON(http.StatusOK, gin.H{
+		resp := gin.H{
 			"success": true,
 			"message": "Attachments uploaded successfully",
-		})
+		}
+		if len(rejected) > 0 {
+			resp["rejected"] = rejected
+		}
+		c.JSON(http.StatusOK, resp)
 	}
 }
 
@@ -242,6 +254,7 @@ func handleCustomerDownloadAttachment(db *sql.DB) gin.HandlerFunc {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Attachment not found"})
 			return
 		}
+		contentBytes = storage.DecodeBytes(contentBytes)
 
 		// If content is empty, try local storage
 		if len(contentBytes) == 0 {
@@ -303,6 +316,7 @@ func handleCustomerGetThumbnail(db *sql.DB) gin.HandlerFunc {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Attachment not found"})
 			return
 		}
+		contentBytes = storage.DecodeBytes(contentBytes)
 
 		// If content is empty, try local storage
 		if len(contentBytes) == 0 {
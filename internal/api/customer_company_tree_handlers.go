@@ -0,0 +1,131 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/service"
+)
+
+// customerCompanyParentRequest is the JSON body of a parent change. An empty
+// or null parent_customer_id moves the company to the top level.
+type customerCompanyParentRequest struct {
+	ParentCustomerID *string `json:"parent_customer_id"`
+}
+
+// customerCompanyTreeService creates a customer company tree service,
+// writing 503 when the database is unavailable.
+func customerCompanyTreeService(c *gin.Context) *service.CustomerCompanyTreeService {
+	db, err := database.GetDB()
+	if err != nil || db == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"success": false, "error": "Database unavailable"})
+		return nil
+	}
+	return service.NewCustomerCompanyTreeService(db)
+}
+
+// customerCompanyTreeWriteError maps CustomerCompanyTreeService errors to
+// responses.
+func customerCompanyTreeWriteError(c *gin.Context, err error, action string) {
+	switch {
+	case errors.Is(err, service.ErrCustomerCompanyNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Customer company not found"})
+	case errors.Is(err, service.ErrCustomerCompanyCycle),
+		errors.Is(err, service.ErrCustomerCompanyTooDeep):
+		c.JSON(http.StatusConflict, gin.H{"success": false, "error": err.Error()})
+	case errors.Is(err, service.ErrCustomerCompanyParentNotFound):
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+	default:
+		log.Printf("customer company api: %s failed: %v", action, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to " + action})
+	}
+}
+
+// HandleCustomerCompanyTreeAPI handles GET /api/v1/customer-companies/tree.
+//
+//	@Summary		Get customer company hierarchy
+//	@Description	Returns the top-level customer companies with their subsidiaries nested in children.
+//	@Tags			Customer Companies
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}	"Company tree"
+//	@Security		BearerAuth
+//	@Router			/customer-companies/tree [get]
+func HandleCustomerCompanyTreeAPI(c *gin.Context) {
+	svc := customerCompanyTreeService(c)
+	if svc == nil {
+		return
+	}
+	roots, err := svc.Tree(c.Request.Context())
+	if err != nil {
+		customerCompanyTreeWriteError(c, err, "load customer company tree")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": roots})
+}
+
+// HandleCustomerCompanyHierarchyAPI handles
+// GET /api/v1/customer-companies/:id/hierarchy.
+//
+//	@Summary		Get a customer company's place in the hierarchy
+//	@Description	Returns the companies above the company, parent first, and the subtree below it.
+//	@Tags			Customer Companies
+//	@Produce		json
+//	@Param			id	path		string					true	"Customer ID"
+//	@Success		200	{object}	map[string]interface{}	"Company hierarchy"
+//	@Failure		404	{object}	map[string]interface{}	"Company not found"
+//	@Security		BearerAuth
+//	@Router			/customer-companies/{id}/hierarchy [get]
+func HandleCustomerCompanyHierarchyAPI(c *gin.Context) {
+	svc := customerCompanyTreeService(c)
+	if svc == nil {
+		return
+	}
+	h, err := svc.Hierarchy(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		customerCompanyTreeWriteError(c, err, "load customer company hierarchy")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": h})
+}
+
+// HandleSetCustomerCompanyParentAPI handles
+// PUT /api/v1/customer-companies/:id/parent.
+//
+//	@Summary		Move a customer company in the hierarchy
+//	@Description	Places the company, with its subsidiaries, below another company or at the top level. Group permissions and ticket visibility follow the new place.
+//	@Tags			Customer Companies
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		string					true	"Customer ID"
+//	@Param			parent	body		object					true	"Parent (parent_customer_id; empty or null for the top level)"
+//	@Success		200		{object}	map[string]interface{}	"Company hierarchy"
+//	@Failure		400		{object}	map[string]interface{}	"Invalid request"
+//	@Failure		404		{object}	map[string]interface{}	"Company not found"
+//	@Failure		409		{object}	map[string]interface{}	"Cycle or too deep"
+//	@Security		BearerAuth
+//	@Router			/customer-companies/{id}/parent [put]
+func HandleSetCustomerCompanyParentAPI(c *gin.Context) {
+	var req customerCompanyParentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid request: " + err.Error()})
+		return
+	}
+	svc := customerCompanyTreeService(c)
+	if svc == nil {
+		return
+	}
+	var parentID string
+	if req.ParentCustomerID != nil {
+		parentID = *req.ParentCustomerID
+	}
+	h, err := svc.SetParent(c.Request.Context(), c.Param("id"), parentID, GetUserIDFromCtx(c, 1))
+	if err != nil {
+		customerCompanyTreeWriteError(c, err, "move customer company")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": h})
+}
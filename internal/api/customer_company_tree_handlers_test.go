@@ -0,0 +1,28 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetCustomerCompanyParentAPI_InvalidBody(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.PUT("/api/v1/customer-companies/:id/parent", HandleSetCustomerCompanyParentAPI)
+
+	for _, body := range []string{`{"parent_customer_id":`, `{"parent_customer_id":7}`} {
+		req := httptest.NewRequest(http.MethodPut, "/api/v1/customer-companies/acme/parent", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, body)
+		assert.Contains(t, w.Body.String(), "Invalid request", body)
+	}
+}
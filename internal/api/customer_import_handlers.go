@@ -0,0 +1,326 @@
+package api
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/service"
+	"github.com/goatkit/goatflow/internal/shared"
+)
+
+// maxCustomerImportUpload is the largest import file accepted.
+const maxCustomerImportUpload = 10 << 20
+
+// customerImportPreviewRows is the most rows the import wizard shows.
+const customerImportPreviewRows = 500
+
+const customerImportTemplate = "pages/admin/customer_import.pongo2"
+
+// customerImportKind parses the :kind path parameter, writing 404 when it
+// is neither users nor companies.
+func customerImportKind(c *gin.Context) (models.CustomerImportKind, bool) {
+	kind := models.CustomerImportKind(c.Param("kind"))
+	if _, err := service.CustomerImportFields(kind); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Customer imports exist for users and companies"})
+		return "", false
+	}
+	return kind, true
+}
+
+// customerImportService creates a customer import service, writing 503 when
+// the database is unavailable.
+func customerImportService(c *gin.Context) *service.CustomerImportService {
+	db, err := database.GetDB()
+	if err != nil || db == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"success": false, "error": "Database unavailable"})
+		return nil
+	}
+	return service.NewCustomerImportService(db)
+}
+
+// customerImportUpload reads the uploaded file from the first of the form
+// fields present.
+func customerImportUpload(c *gin.Context, fields ...string) (string, []byte, error) {
+	for _, field := range fields {
+		header, err := c.FormFile(field)
+		if err != nil {
+			continue
+		}
+		if header.Size > maxCustomerImportUpload {
+			return "", nil, fmt.Errorf("the file is larger than %d MB", maxCustomerImportUpload>>20)
+		}
+		f, err := header.Open()
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to read uploaded file")
+		}
+		defer f.Close()
+		data, err := io.ReadAll(io.LimitReader(f, maxCustomerImportUpload))
+		if err != nil {
+			return "", nil, fmt.Errorf("failed to read uploaded file")
+		}
+		return header.Filename, data, nil
+	}
+	return "", nil, fmt.Errorf("no file uploaded")
+}
+
+// customerImportAPIRequest reads the file and options of an import API
+// request, writing 400 when they are invalid.
+func customerImportAPIRequest(c *gin.Context) (string, []byte, service.CustomerImportOptions, bool) {
+	var opts service.CustomerImportOptions
+	filename, data, err := customerImportUpload(c, "file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid request: " + err.Error()})
+		return "", nil, opts, false
+	}
+	if raw := c.PostForm("mapping"); raw != "" {
+		if err := json.Unmarshal([]byte(raw), &opts.Mapping); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid request: mapping must be a JSON array of field names"})
+			return "", nil, opts, false
+		}
+	}
+	opts.UpdateExisting, _ = strconv.ParseBool(c.PostForm("update_existing"))
+	return filename, data, opts, true
+}
+
+// customerImportError writes the response for an error of the import
+// service: 400 for problems with the file or mapping, 500 otherwise.
+func customerImportError(c *gin.Context, kind models.CustomerImportKind, err error) {
+	if errors.Is(err, service.ErrCustomerImportFile) || errors.Is(err, service.ErrCustomerImportMapping) {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	log.Printf("customer import api: import %s failed: %v", kind, err)
+	c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to import customer " + string(kind)})
+}
+
+// HandlePreviewCustomerImportAPI handles POST /api/v1/customer-imports/:kind/preview.
+//
+//	@Summary		Preview a customer import
+//	@Description	Reads a CSV or Excel file of customer users or companies, maps its columns to fields and validates every row without writing anything.
+//	@Tags			Customer Imports
+//	@Accept			multipart/form-data
+//	@Produce		json
+//	@Param			kind			path		string					true	"users or companies"
+//	@Param			file			formData	file					true	"CSV or Excel file"
+//	@Param			mapping			formData	string					false	"JSON array of field names, one per column"
+//	@Param			update_existing	formData	boolean					false	"Update existing records instead of skipping them"
+//	@Success		200				{object}	map[string]interface{}	"Import preview"
+//	@Failure		400				{object}	map[string]interface{}	"Invalid file or mapping"
+//	@Failure		404				{object}	map[string]interface{}	"Unknown import kind"
+//	@Security		BearerAuth
+//	@Router			/customer-imports/{kind}/preview [post]
+func HandlePreviewCustomerImportAPI(c *gin.Context) {
+	kind, ok := customerImportKind(c)
+	if !ok {
+		return
+	}
+	filename, data, opts, ok := customerImportAPIRequest(c)
+	if !ok {
+		return
+	}
+	svc := customerImportService(c)
+	if svc == nil {
+		return
+	}
+	preview, err := svc.Preview(c.Request.Context(), kind, filename, data, opts)
+	if err != nil {
+		customerImportError(c, kind, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": preview})
+}
+
+// HandleCustomerImportAPI handles POST /api/v1/customer-imports/:kind.
+//
+//	@Summary		Import customer users or companies
+//	@Description	Creates, and optionally updates, customer users or companies from a CSV or Excel file. Invalid rows are reported and left out; the valid rows are written in one transaction. With format=csv the result is returned as a CSV report.
+//	@Tags			Customer Imports
+//	@Accept			multipart/form-data
+//	@Produce		json
+//	@Produce		text/csv
+//	@Param			kind			path		string					true	"users or companies"
+//	@Param			format			query		string					false	"csv for a CSV report"
+//	@Param			file			formData	file					true	"CSV or Excel file"
+//	@Param			mapping			formData	string					false	"JSON array of field names, one per column"
+//	@Param			update_existing	formData	boolean					false	"Update existing records instead of skipping them"
+//	@Success		200				{object}	map[string]interface{}	"Import result"
+//	@Failure		400				{object}	map[string]interface{}	"Invalid file or mapping"
+//	@Failure		404				{object}	map[string]interface{}	"Unknown import kind"
+//	@Security		BearerAuth
+//	@Router			/customer-imports/{kind} [post]
+func HandleCustomerImportAPI(c *gin.Context) {
+	kind, ok := customerImportKind(c)
+	if !ok {
+		return
+	}
+	filename, data, opts, ok := customerImportAPIRequest(c)
+	if !ok {
+		return
+	}
+	svc := customerImportService(c)
+	if svc == nil {
+		return
+	}
+	result, err := svc.Import(c.Request.Context(), kind, filename, data, opts, GetUserIDFromCtx(c, 1))
+	if err != nil {
+		customerImportError(c, kind, err)
+		return
+	}
+	if c.Query("format") == "csv" {
+		var report bytes.Buffer
+		if err := service.WriteCustomerImportReport(&report, result); err != nil {
+			log.Printf("customer import api: write report failed: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to write import report"})
+			return
+		}
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=customer_%s_import_report.csv", kind))
+		c.Data(http.StatusOK, "text/csv; charset=utf-8", report.Bytes())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": result})
+}
+
+// customerImportPage renders the import wizard, or plain text when no
+// renderer is configured.
+func customerImportPage(c *gin.Context, status int, data gin.H) {
+	renderer := shared.GetGlobalRenderer()
+	if renderer == nil {
+		if msg, ok := data["Error"].(string); ok {
+			c.String(status, msg)
+			return
+		}
+		c.String(status, "<h1>Import Customers</h1>")
+		return
+	}
+	data["Title"] = "Import Customer Users and Companies"
+	data["ActivePage"] = "admin"
+	renderer.HTML(c, status, customerImportTemplate, data)
+}
+
+// wizardImportKind reads the import kind of a wizard form, defaulting to
+// customer users.
+func wizardImportKind(value string) models.CustomerImportKind {
+	if models.CustomerImportKind(value) == models.CustomerImportCompanies {
+		return models.CustomerImportCompanies
+	}
+	return models.CustomerImportUsers
+}
+
+// HandleAdminCustomerImportPage handles GET /admin/customer-import.
+func HandleAdminCustomerImportPage(c *gin.Context) {
+	customerImportPage(c, http.StatusOK, gin.H{
+		"ShowUpload": true,
+		"Kind":       string(wizardImportKind(c.Query("kind"))),
+	})
+}
+
+// customerImportWizardFile reads the file of a wizard step: a new upload,
+// or the file carried over from the previous step in a hidden field.
+func customerImportWizardFile(c *gin.Context) (string, []byte, error) {
+	if encoded := c.PostForm("file_data"); encoded != "" {
+		data, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return "", nil, fmt.Errorf("the uploaded file was lost, please upload it again")
+		}
+		return c.PostForm("filename"), data, nil
+	}
+	return customerImportUpload(c, "file")
+}
+
+// customerImportWizardOptions reads the mapping and update choice of a
+// wizard form. Without a mapping the columns are mapped by their headers.
+func customerImportWizardOptions(c *gin.Context) service.CustomerImportOptions {
+	opts := service.CustomerImportOptions{UpdateExisting: c.PostForm("update_existing") == "1"}
+	if mapping := c.PostFormArray("mapping"); len(mapping) > 0 {
+		opts.Mapping = mapping
+	}
+	return opts
+}
+
+// HandleAdminCustomerImportPreview handles POST /admin/customer-import/preview.
+// It shows the column mapping and the action for each row, and is posted
+// again when the mapping is changed.
+func HandleAdminCustomerImportPreview(c *gin.Context) {
+	kind := wizardImportKind(c.PostForm("kind"))
+	filename, data, err := customerImportWizardFile(c)
+	if err != nil {
+		customerImportPage(c, http.StatusBadRequest, gin.H{"ShowUpload": true, "Kind": string(kind), "Error": "Import failed: " + err.Error()})
+		return
+	}
+	db, err := database.GetDB()
+	if err != nil || db == nil {
+		customerImportPage(c, http.StatusServiceUnavailable, gin.H{"ShowUpload": true, "Kind": string(kind), "Error": "Database unavailable"})
+		return
+	}
+
+	opts := customerImportWizardOptions(c)
+	preview, err := service.NewCustomerImportService(db).Preview(c.Request.Context(), kind, filename, data, opts)
+	if err != nil {
+		customerImportPage(c, http.StatusBadRequest, gin.H{"ShowUpload": true, "Kind": string(kind), "Error": err.Error()})
+		return
+	}
+
+	columns := make([]gin.H, len(preview.Headers))
+	for i, header := range preview.Headers {
+		columns[i] = gin.H{"Header": header, "Field": preview.Mapping[i]}
+	}
+	rows := preview.Rows
+	if len(rows) > customerImportPreviewRows {
+		rows = rows[:customerImportPreviewRows]
+	}
+	customerImportPage(c, http.StatusOK, gin.H{
+		"ShowPreview":    true,
+		"Kind":           string(kind),
+		"Preview":        preview,
+		"Columns":        columns,
+		"Rows":           rows,
+		"RowsHidden":     len(preview.Rows) - len(rows),
+		"Filename":       filename,
+		"FileData":       base64.StdEncoding.EncodeToString(data),
+		"UpdateExisting": opts.UpdateExisting,
+	})
+}
+
+// HandleAdminCustomerImportConfirm handles POST /admin/customer-import/confirm.
+func HandleAdminCustomerImportConfirm(c *gin.Context) {
+	kind := wizardImportKind(c.PostForm("kind"))
+	filename, data, err := customerImportWizardFile(c)
+	if err != nil {
+		customerImportPage(c, http.StatusBadRequest, gin.H{"ShowUpload": true, "Kind": string(kind), "Error": "Import failed: " + err.Error()})
+		return
+	}
+	db, err := database.GetDB()
+	if err != nil || db == nil {
+		customerImportPage(c, http.StatusServiceUnavailable, gin.H{"ShowUpload": true, "Kind": string(kind), "Error": "Database unavailable"})
+		return
+	}
+
+	result, err := service.NewCustomerImportService(db).Import(c.Request.Context(), kind, filename, data, customerImportWizardOptions(c), getUserID(c))
+	if err != nil {
+		log.Printf("customer import: import %s failed: %v", kind, err)
+		customerImportPage(c, http.StatusInternalServerError, gin.H{"ShowUpload": true, "Kind": string(kind), "Error": "Import failed: " + err.Error()})
+		return
+	}
+
+	var report bytes.Buffer
+	if err := service.WriteCustomerImportReport(&report, result); err != nil {
+		log.Printf("customer import: write report failed: %v", err)
+	}
+	customerImportPage(c, http.StatusOK, gin.H{
+		"ShowResults": true,
+		"Kind":        string(kind),
+		"Result":      result,
+		"ReportData":  base64.StdEncoding.EncodeToString(report.Bytes()),
+	})
+}
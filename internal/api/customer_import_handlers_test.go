@@ -0,0 +1,70 @@
+package api
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func customerImportRequest(t *testing.T, path string, withFile bool, fields map[string]string) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	if withFile {
+		part, err := w.CreateFormFile("file", "users.csv")
+		require.NoError(t, err)
+		_, err = part.Write([]byte("login;email;customer_id\njdoe;jdoe@example.com;acme\n"))
+		require.NoError(t, err)
+	}
+	for k, v := range fields {
+		require.NoError(t, w.WriteField(k, v))
+	}
+	require.NoError(t, w.Close())
+
+	req := httptest.NewRequest(http.MethodPost, path, &body)
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	return req
+}
+
+func TestCustomerImportAPI_InvalidRequests(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.POST("/api/v1/customer-imports/:kind/preview", HandlePreviewCustomerImportAPI)
+	router.POST("/api/v1/customer-imports/:kind", HandleCustomerImportAPI)
+
+	for _, tc := range []struct {
+		name     string
+		path     string
+		withFile bool
+		fields   map[string]string
+		code     int
+		want     string
+	}{
+		{"unknown kind", "/api/v1/customer-imports/agents", true, nil, http.StatusNotFound, "users and companies"},
+		{"unknown kind preview", "/api/v1/customer-imports/tickets/preview", true, nil, http.StatusNotFound, "users and companies"},
+		{"no file", "/api/v1/customer-imports/users", false, nil, http.StatusBadRequest, "no file uploaded"},
+		{"mapping not JSON", "/api/v1/customer-imports/companies/preview", true, map[string]string{"mapping": "login,email"}, http.StatusBadRequest, "JSON array"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, customerImportRequest(t, tc.path, tc.withFile, tc.fields))
+
+			assert.Equal(t, tc.code, w.Code)
+			assert.Contains(t, w.Body.String(), tc.want)
+		})
+	}
+}
+
+func TestWizardImportKind(t *testing.T) {
+	assert.Equal(t, "companies", string(wizardImportKind("companies")))
+	assert.Equal(t, "users", string(wizardImportKind("users")))
+	assert.Equal(t, "users", string(wizardImportKind("")))
+	assert.Equal(t, "users", string(wizardImportKind("agents")))
+}
@@ -15,6 +15,7 @@ func CustomerOnlyGuard(enabled bool) gin.HandlerFunc {
 	allowedPrefixes := []string{
 		"/customer",
 		"/auth/customer",
+		"/auth/sso", // Single sign-on redirects and SAML ACS
 		"/login",
 		"/api/auth",
 		"/api/languages", // Public language selector API
@@ -26,6 +27,7 @@ func CustomerOnlyGuard(enabled bool) gin.HandlerFunc {
 		"/static",
 		"/assets",
 		"/runtime",
+		"/branding", // Branding logo and favicon
 	}
 
 	allowedExact := map[string]struct{}{
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"log"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/service"
+)
+
+// portalDomainForRequest returns the customer portal domain the request was
+// made on, or nil on the main host. The customer portal middleware resolves
+// it for /customer routes; login routes resolve it here.
+func portalDomainForRequest(c *gin.Context) *models.PortalDomain {
+	if v, ok := c.Get("portal_domain"); ok {
+		d, _ := v.(*models.PortalDomain)
+		return d
+	}
+	db, err := database.GetDB()
+	if err != nil || db == nil {
+		return nil
+	}
+	d, err := service.NewPortalDomainService(db).Resolve(c.Request.Context(), c.Request.Host)
+	if err != nil {
+		log.Printf("portal domain lookup for %q failed: %v", c.Request.Host, err)
+		return nil
+	}
+	c.Set("portal_domain", d)
+	return d
+}
+
+// portalQueueFilter returns an SQL condition limiting tickets to the queues
+// visible on the request's portal domain, with its arguments. The condition
+// is empty when the portal shows every queue.
+func portalQueueFilter(c *gin.Context, column string) (string, []interface{}) {
+	d := portalDomainForRequest(c)
+	if d == nil || len(d.QueueIDs) == 0 {
+		return "", nil
+	}
+	placeholders := make([]string, len(d.QueueIDs))
+	args := make([]interface{}, len(d.QueueIDs))
+	for i, id := range d.QueueIDs {
+		placeholders[i] = "?"
+		args[i] = id
+	}
+	return " AND " + column + " IN (" + strings.Join(placeholders, ",") + ")", args
+}
+
+// portalDomainAllowsCustomer reports whether the customer may sign in on
+// the request's portal domain. Every customer may sign in on the main host.
+func portalDomainAllowsCustomer(c *gin.Context, login string) bool {
+	d := portalDomainForRequest(c)
+	if d == nil {
+		return true
+	}
+	db, err := database.GetDB()
+	if err != nil || db == nil {
+		return false
+	}
+	ok, err := service.NewPortalDomainService(db).AllowsCustomer(c.Request.Context(), d, login)
+	if err != nil {
+		log.Printf("portal domain %s: customer check for %q failed: %v", d.Domain, login, err)
+		return false
+	}
+	return ok
+}
@@ -0,0 +1,352 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/goatkit/goatflow/internal/auth"
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/service"
+	"github.com/goatkit/goatflow/internal/sysconfig"
+)
+
+// customerRegistrationLimiter limits signups per client IP: after five
+// within an hour further ones are refused for a while.
+var customerRegistrationLimiter = auth.NewLoginRateLimiter(5, 3600, 10*time.Minute, time.Hour)
+
+// customerRegistrationAccepted is the answer to every accepted signup, so
+// it does not tell whether the address already has an account.
+const customerRegistrationAccepted = "If the address can be registered, we have sent a link to confirm it. Please check your email."
+
+// customerRegistrationService returns the service, writing 503 when the
+// database is unavailable.
+func customerRegistrationService(c *gin.Context) *service.CustomerRegistrationService {
+	db, err := database.GetDB()
+	if err != nil || db == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"success": false, "error": "Database unavailable"})
+		return nil
+	}
+	return service.NewCustomerRegistrationService(db)
+}
+
+// customerRegistrationMessage returns the message shown to the customer
+// for a registration error, and its status; ok is false for unexpected
+// errors.
+func customerRegistrationMessage(err error) (int, string, bool) {
+	var pwErr *service.RegistrationPasswordError
+	switch {
+	case errors.As(err, &pwErr):
+		return http.StatusBadRequest, getPasswordPolicyErrorMessage(pwErr.Code), true
+	case errors.Is(err, service.ErrRegistrationDisabled):
+		return http.StatusNotFound, "Registration is not available", true
+	case errors.Is(err, service.ErrRegistrationInvalid), errors.Is(err, service.ErrRegistrationDomain):
+		return http.StatusBadRequest, err.Error(), true
+	case errors.Is(err, service.ErrRegistrationCaptcha):
+		return http.StatusBadRequest, "Please confirm that you are not a robot", true
+	case errors.Is(err, service.ErrRegistrationLinkExpired):
+		return http.StatusGone, "This link has expired. Please register again.", true
+	case errors.Is(err, service.ErrRegistrationLinkInvalid):
+		return http.StatusNotFound, "This link is invalid or was already used", true
+	case errors.Is(err, service.ErrRegistrationExists):
+		return http.StatusConflict, "An account with this email address already exists. Please sign in.", true
+	}
+	return http.StatusInternalServerError, "Registration failed, please try again later", false
+}
+
+// customerRegistrationError maps CustomerRegistrationService errors to
+// responses.
+func customerRegistrationError(c *gin.Context, err error, action string) {
+	var pwErr *service.RegistrationPasswordError
+	switch {
+	case errors.As(err, &pwErr):
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": getPasswordPolicyErrorMessage(pwErr.Code), "code": pwErr.Code})
+	case errors.Is(err, service.ErrRegistrationNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Registration not found"})
+	case errors.Is(err, service.ErrRegistrationDecided):
+		c.JSON(http.StatusConflict, gin.H{"success": false, "error": "Registration was already decided"})
+	case errors.Is(err, service.ErrRegistrationSettings):
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+	default:
+		status, msg, ok := customerRegistrationMessage(err)
+		if !ok {
+			log.Printf("customer registration api: %s failed: %v", action, err)
+			msg = "Failed to " + action
+		}
+		c.JSON(status, gin.H{"success": false, "error": msg})
+	}
+}
+
+// customerRegistrationRateLimited writes 429 when the client IP made too
+// many signups, and otherwise counts this one.
+func customerRegistrationRateLimited(c *gin.Context) (time.Duration, bool) {
+	ip := c.ClientIP()
+	if blocked, remaining := customerRegistrationLimiter.IsBlocked(ip, "register"); blocked {
+		return remaining, true
+	}
+	customerRegistrationLimiter.RecordFailure(ip, "register")
+	return 0, false
+}
+
+// HandleCustomerRegistrationConfigAPI handles GET /api/auth/customer/register.
+//
+//	@Summary		Customer registration options
+//	@Description	Tells portal clients whether self-registration is open and which CAPTCHA to show.
+//	@Tags			Customer Registration
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}	"Registration options"
+//	@Router			/auth/customer/register [get]
+func HandleCustomerRegistrationConfigAPI(c *gin.Context) {
+	svc := customerRegistrationService(c)
+	if svc == nil {
+		return
+	}
+	cfg, err := svc.Settings()
+	if err != nil {
+		customerRegistrationError(c, err, "load registration options")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{
+		"enabled":          cfg.Enabled,
+		"require_approval": cfg.RequireApproval,
+		"captcha_provider": cfg.Captcha.Provider,
+		"captcha_site_key": cfg.Captcha.SiteKey,
+	}})
+}
+
+// HandleCustomerRegisterAPI handles POST /api/auth/customer/register.
+//
+//	@Summary		Register customer account
+//	@Description	Starts a customer self-registration and emails a verification link. The answer is the same whether or not the address already has an account.
+//	@Tags			Customer Registration
+//	@Accept			json
+//	@Produce		json
+//	@Param			registration	body		object	true	"email, first_name, last_name, password, captcha_response"
+//	@Success		202				{object}	map[string]interface{}	"Verification link sent"
+//	@Failure		400				{object}	map[string]interface{}	"Invalid registration"
+//	@Failure		404				{object}	map[string]interface{}	"Registration disabled"
+//	@Failure		429				{object}	map[string]interface{}	"Too many registrations"
+//	@Router			/auth/customer/register [post]
+func HandleCustomerRegisterAPI(c *gin.Context) {
+	var in service.CustomerRegistrationInput
+	if err := c.ShouldBindJSON(&in); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid request body"})
+		return
+	}
+	if remaining, limited := customerRegistrationRateLimited(c); limited {
+		c.JSON(http.StatusTooManyRequests, gin.H{
+			"success":         false,
+			"error":           fmt.Sprintf("too many registrations, try again in %d seconds", int(remaining.Seconds())),
+			"retry_after_sec": int(remaining.Seconds()),
+		})
+		return
+	}
+	svc := customerRegistrationService(c)
+	if svc == nil {
+		return
+	}
+	in.RemoteIP = c.ClientIP()
+	if err := svc.Register(c.Request.Context(), in); err != nil {
+		customerRegistrationError(c, err, "register")
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"success": true, "message": customerRegistrationAccepted})
+}
+
+// HandleCustomerVerifyRegistrationAPI handles POST /api/auth/customer/register/verify.
+//
+//	@Summary		Verify customer registration
+//	@Description	Confirms the email address of a registration with the token from the emailed link. The status is approved when the account was created, or pending when it awaits admin approval.
+//	@Tags			Customer Registration
+//	@Accept			json
+//	@Produce		json
+//	@Param			verification	body		object	true	"token"
+//	@Success		200				{object}	map[string]interface{}	"Registration status"
+//	@Failure		404				{object}	map[string]interface{}	"Invalid link"
+//	@Failure		410				{object}	map[string]interface{}	"Expired link"
+//	@Router			/auth/customer/register/verify [post]
+func HandleCustomerVerifyRegistrationAPI(c *gin.Context) {
+	var req struct {
+		Token string `json:"token"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || req.Token == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "token is required"})
+		return
+	}
+	svc := customerRegistrationService(c)
+	if svc == nil {
+		return
+	}
+	reg, err := svc.Verify(c.Request.Context(), req.Token)
+	if err != nil {
+		customerRegistrationError(c, err, "verify registration")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{"status": reg.Status, "email": reg.Email}})
+}
+
+// HandleListCustomerRegistrationsAPI handles GET /api/v1/admin/customer-registrations.
+//
+//	@Summary		List customer registrations
+//	@Description	Lists customer self-registrations, newest first. Use status=pending for those awaiting approval.
+//	@Tags			Customer Registration
+//	@Produce		json
+//	@Param			status	query		string	false	"unverified, pending, approved or rejected"
+//	@Param			limit	query		int		false	"Page size (default 50, at most 500)"
+//	@Param			offset	query		int		false	"Offset"
+//	@Success		200		{object}	map[string]interface{}	"Registrations"
+//	@Security		BearerAuth
+//	@Router			/admin/customer-registrations [get]
+func HandleListCustomerRegistrationsAPI(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if limit <= 0 || limit > 500 {
+		limit = 50
+	}
+	offset, _ := strconv.Atoi(c.Query("offset"))
+	if offset < 0 {
+		offset = 0
+	}
+	svc := customerRegistrationService(c)
+	if svc == nil {
+		return
+	}
+	list, err := svc.List(c.Request.Context(), c.Query("status"), limit, offset)
+	if err != nil {
+		customerRegistrationError(c, err, "load registrations")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": list})
+}
+
+// customerRegistrationID parses the :id parameter, writing 400 when it is
+// invalid.
+func customerRegistrationID(c *gin.Context) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid registration ID"})
+		return 0, false
+	}
+	return id, true
+}
+
+// HandleApproveCustomerRegistrationAPI handles POST /api/v1/admin/customer-registrations/:id/approve.
+//
+//	@Summary		Approve customer registration
+//	@Description	Creates the customer account of a verified registration awaiting approval and emails the customer.
+//	@Tags			Customer Registration
+//	@Produce		json
+//	@Param			id	path		int	true	"Registration ID"
+//	@Success		200	{object}	map[string]interface{}	"Approved registration"
+//	@Failure		404	{object}	map[string]interface{}	"Registration not found"
+//	@Failure		409	{object}	map[string]interface{}	"Registration not pending or account exists"
+//	@Security		BearerAuth
+//	@Router			/admin/customer-registrations/{id}/approve [post]
+func HandleApproveCustomerRegistrationAPI(c *gin.Context) {
+	id, ok := customerRegistrationID(c)
+	if !ok {
+		return
+	}
+	svc := customerRegistrationService(c)
+	if svc == nil {
+		return
+	}
+	reg, err := svc.Approve(c.Request.Context(), id, GetUserIDFromCtx(c, 1))
+	if err != nil {
+		customerRegistrationError(c, err, "approve registration")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": reg})
+}
+
+// HandleRejectCustomerRegistrationAPI handles POST /api/v1/admin/customer-registrations/:id/reject.
+//
+//	@Summary		Reject customer registration
+//	@Description	Declines an unverified or pending registration; its link stops working.
+//	@Tags			Customer Registration
+//	@Produce		json
+//	@Param			id	path		int	true	"Registration ID"
+//	@Success		200	{object}	map[string]interface{}	"Rejected registration"
+//	@Failure		404	{object}	map[string]interface{}	"Registration not found"
+//	@Failure		409	{object}	map[string]interface{}	"Registration already decided"
+//	@Security		BearerAuth
+//	@Router			/admin/customer-registrations/{id}/reject [post]
+func HandleRejectCustomerRegistrationAPI(c *gin.Context) {
+	id, ok := customerRegistrationID(c)
+	if !ok {
+		return
+	}
+	svc := customerRegistrationService(c)
+	if svc == nil {
+		return
+	}
+	reg, err := svc.Reject(c.Request.Context(), id, GetUserIDFromCtx(c, 1))
+	if err != nil {
+		customerRegistrationError(c, err, "reject registration")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": reg})
+}
+
+// customerRegistrationSettingsView hides the CAPTCHA secret.
+func customerRegistrationSettingsView(cfg sysconfig.CustomerRegistrationConfig) gin.H {
+	secretSet := cfg.Captcha.Secret != ""
+	cfg.Captcha.Secret = ""
+	return gin.H{"settings": cfg, "captcha_secret_set": secretSet, "captcha_providers": service.CaptchaProviders()}
+}
+
+// HandleGetCustomerRegistrationSettingsAPI handles GET /api/v1/admin/customer-registrations/settings.
+//
+//	@Summary		Get customer registration settings
+//	@Description	Returns the self-registration settings. The CAPTCHA secret is never returned; captcha_secret_set tells whether one is stored.
+//	@Tags			Customer Registration
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}	"Registration settings"
+//	@Security		BearerAuth
+//	@Router			/admin/customer-registrations/settings [get]
+func HandleGetCustomerRegistrationSettingsAPI(c *gin.Context) {
+	svc := customerRegistrationService(c)
+	if svc == nil {
+		return
+	}
+	cfg, err := svc.Settings()
+	if err != nil {
+		customerRegistrationError(c, err, "load registration settings")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": customerRegistrationSettingsView(cfg)})
+}
+
+// HandleUpdateCustomerRegistrationSettingsAPI handles PUT /api/v1/admin/customer-registrations/settings.
+//
+//	@Summary		Update customer registration settings
+//	@Description	Sets whether registration is open, approval mode, the email domain to customer company mappings, the link validity and the CAPTCHA. An empty captcha secret keeps the stored one.
+//	@Tags			Customer Registration
+//	@Accept			json
+//	@Produce		json
+//	@Param			settings	body		object	true	"Registration settings"
+//	@Success		200			{object}	map[string]interface{}	"Registration settings"
+//	@Failure		400			{object}	map[string]interface{}	"Invalid settings"
+//	@Security		BearerAuth
+//	@Router			/admin/customer-registrations/settings [put]
+func HandleUpdateCustomerRegistrationSettingsAPI(c *gin.Context) {
+	var cfg sysconfig.CustomerRegistrationConfig
+	if err := c.ShouldBindJSON(&cfg); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid request body"})
+		return
+	}
+	svc := customerRegistrationService(c)
+	if svc == nil {
+		return
+	}
+	saved, err := svc.SaveSettings(cfg, GetUserIDFromCtx(c, 1))
+	if err != nil {
+		customerRegistrationError(c, err, "save registration settings")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": customerRegistrationSettingsView(saved)})
+}
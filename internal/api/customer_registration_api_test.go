@@ -0,0 +1,70 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/goatkit/goatflow/internal/service"
+)
+
+func TestCustomerRegistrationHandlers_InvalidRequest(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.POST("/api/auth/customer/register", HandleCustomerRegisterAPI)
+	router.POST("/api/auth/customer/register/verify", HandleCustomerVerifyRegistrationAPI)
+	router.POST("/api/v1/admin/customer-registrations/:id/approve", HandleApproveCustomerRegistrationAPI)
+	router.POST("/api/v1/admin/customer-registrations/:id/reject", HandleRejectCustomerRegistrationAPI)
+	router.PUT("/api/v1/admin/customer-registrations/settings", HandleUpdateCustomerRegistrationSettingsAPI)
+
+	for _, tc := range []struct {
+		method string
+		path   string
+		body   string
+		want   string
+	}{
+		{http.MethodPost, "/api/auth/customer/register", `{"email": 42}`, "Invalid request body"},
+		{http.MethodPost, "/api/auth/customer/register/verify", `{}`, "token is required"},
+		{http.MethodPost, "/api/v1/admin/customer-registrations/abc/approve", ``, "Invalid registration ID"},
+		{http.MethodPost, "/api/v1/admin/customer-registrations/0/reject", ``, "Invalid registration ID"},
+		{http.MethodPut, "/api/v1/admin/customer-registrations/settings", `{"enabled": "yes"}`, "Invalid request body"},
+	} {
+		t.Run(tc.method+" "+tc.path+" "+tc.body, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Contains(t, w.Body.String(), tc.want)
+		})
+	}
+}
+
+func TestCustomerRegistrationMessage(t *testing.T) {
+	for _, tc := range []struct {
+		err    error
+		status int
+		known  bool
+	}{
+		{&service.RegistrationPasswordError{Code: "min_size"}, http.StatusBadRequest, true},
+		{fmt.Errorf("%w: a valid email address is required", service.ErrRegistrationInvalid), http.StatusBadRequest, true},
+		{service.ErrRegistrationCaptcha, http.StatusBadRequest, true},
+		{service.ErrRegistrationDisabled, http.StatusNotFound, true},
+		{service.ErrRegistrationLinkExpired, http.StatusGone, true},
+		{service.ErrRegistrationExists, http.StatusConflict, true},
+		{errors.New("connection refused"), http.StatusInternalServerError, false},
+	} {
+		status, msg, known := customerRegistrationMessage(tc.err)
+		assert.Equal(t, tc.status, status, tc.err.Error())
+		assert.Equal(t, tc.known, known, tc.err.Error())
+		assert.NotContains(t, msg, "connection refused")
+	}
+}
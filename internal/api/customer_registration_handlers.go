@@ -0,0 +1,155 @@
+package api
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/flosch/pongo2/v6"
+	"github.com/gin-gonic/gin"
+
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/service"
+	"github.com/goatkit/goatflow/internal/sysconfig"
+)
+
+// renderCustomerRegister renders the registration page in one of its
+// states: form, sent, disabled, confirm, approved, pending, exists, expired
+// or invalid.
+func renderCustomerRegister(c *gin.Context, status int, state string, ctx pongo2.Context) {
+	if ctx == nil {
+		ctx = pongo2.Context{}
+	}
+	ctx["State"] = state
+	if db, err := database.GetDB(); err == nil && db != nil {
+		ctx = withPortalContext(ctx, customerPortalConfigFromContext(c, db))
+	}
+	getPongo2Renderer().HTML(c, status, "pages/customer/register.pongo2", ctx)
+}
+
+// customerRegistrationSettings loads the registration settings, rendering
+// the page as unavailable when registration is off.
+func customerRegistrationSettings(c *gin.Context) (*service.CustomerRegistrationService, sysconfig.CustomerRegistrationConfig, bool) {
+	db, err := database.GetDB()
+	if err != nil || db == nil {
+		c.String(http.StatusServiceUnavailable, "Service unavailable")
+		return nil, sysconfig.CustomerRegistrationConfig{}, false
+	}
+	svc := service.NewCustomerRegistrationService(db)
+	cfg, err := svc.Settings()
+	if err != nil {
+		log.Printf("customer registration: loading settings failed: %v", err)
+	}
+	if !cfg.Enabled {
+		renderCustomerRegister(c, http.StatusNotFound, "disabled", nil)
+		return nil, cfg, false
+	}
+	return svc, cfg, true
+}
+
+// customerRegistrationEnabled tells the login page whether to offer
+// registration.
+func customerRegistrationEnabled() bool {
+	db, err := database.GetDB()
+	if err != nil || db == nil {
+		return false
+	}
+	cfg, _ := sysconfig.LoadCustomerRegistrationConfig(db)
+	return cfg.Enabled
+}
+
+// captchaFormResponse returns the response the CAPTCHA widget posted; each
+// provider uses its own field name.
+func captchaFormResponse(c *gin.Context) string {
+	for _, field := range []string{"cf-turnstile-response", "h-captcha-response", "g-recaptcha-response", "captcha_response"} {
+		if v := c.PostForm(field); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// handleCustomerRegisterPage shows the registration form.
+func handleCustomerRegisterPage(c *gin.Context) {
+	_, cfg, ok := customerRegistrationSettings(c)
+	if !ok {
+		return
+	}
+	renderCustomerRegister(c, http.StatusOK, "form", pongo2.Context{"Captcha": cfg.Captcha})
+}
+
+// handleCustomerRegisterSubmit starts a registration from the form. Errors
+// show the form again with the customer's input, except the password.
+func handleCustomerRegisterSubmit(c *gin.Context) {
+	svc, cfg, ok := customerRegistrationSettings(c)
+	if !ok {
+		return
+	}
+	in := service.CustomerRegistrationInput{
+		Email:           c.PostForm("email"),
+		FirstName:       c.PostForm("first_name"),
+		LastName:        c.PostForm("last_name"),
+		Password:        c.PostForm("password"),
+		CaptchaResponse: captchaFormResponse(c),
+		RemoteIP:        c.ClientIP(),
+	}
+	form := pongo2.Context{"Captcha": cfg.Captcha, "Input": in}
+	if in.Password != c.PostForm("password_confirm") {
+		form["error"] = "The passwords do not match"
+		renderCustomerRegister(c, http.StatusBadRequest, "form", form)
+		return
+	}
+	if remaining, limited := customerRegistrationRateLimited(c); limited {
+		form["error"] = fmt.Sprintf("Too many registrations. Please try again in %d minutes.", int(remaining.Minutes())+1)
+		renderCustomerRegister(c, http.StatusTooManyRequests, "form", form)
+		return
+	}
+	if err := svc.Register(c.Request.Context(), in); err != nil {
+		status, msg, known := customerRegistrationMessage(err)
+		if !known {
+			log.Printf("customer registration: register failed: %v", err)
+		}
+		form["error"] = msg
+		renderCustomerRegister(c, status, "form", form)
+		return
+	}
+	renderCustomerRegister(c, http.StatusOK, "sent", pongo2.Context{"Email": in.Email})
+}
+
+// handleCustomerVerifyRegistrationPage shows the confirmation button of an
+// emailed verification link. Verifying takes a POST, so link scanners that
+// open emailed links do not verify on the customer's behalf.
+func handleCustomerVerifyRegistrationPage(c *gin.Context) {
+	renderCustomerRegister(c, http.StatusOK, "confirm", nil)
+}
+
+// handleCustomerVerifyRegistration verifies the email address of a
+// registration.
+func handleCustomerVerifyRegistration(c *gin.Context) {
+	svc, _, ok := customerRegistrationSettings(c)
+	if !ok {
+		return
+	}
+	reg, err := svc.Verify(c.Request.Context(), c.Param("token"))
+	if err != nil {
+		status, msg, known := customerRegistrationMessage(err)
+		if !known {
+			log.Printf("customer registration: verify failed: %v", err)
+		}
+		state := "invalid"
+		switch status {
+		case http.StatusGone:
+			state = "expired"
+		case http.StatusConflict:
+			state = "exists"
+		}
+		renderCustomerRegister(c, status, state, pongo2.Context{"error": msg})
+		return
+	}
+	state := "approved"
+	if reg.Status == models.CustomerRegistrationPending {
+		state = "pending"
+	}
+	renderCustomerRegister(c, http.StatusOK, state, pongo2.Context{"Email": reg.Email})
+}
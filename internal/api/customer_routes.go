@@ -17,6 +17,7 @@ import (
 	"github.com/goatkit/goatflow/internal/database"
 	"github.com/goatkit/goatflow/internal/i18n"
 	"github.com/goatkit/goatflow/internal/service"
+	"github.com/goatkit/goatflow/internal/storage"
 	"github.com/goatkit/goatflow/internal/sysconfig"
 	"github.com/goatkit/goatflow/internal/utils"
 )
@@ -164,20 +165,25 @@ func handleCustomerDashboard(db *sql.DB) gin.HandlerFunc {
 			AvgResponseTime string
 		}{}
 
+		// Portal domains can limit the tickets shown to some queues
+		queueFilter, queueArgs := portalQueueFilter(c, "queue_id")
+		recentFilter, _ := portalQueueFilter(c, "t.queue_id")
+		ticketArgs := append([]interface{}{username}, queueArgs...)
+
 		// Count open tickets for this customer
 		row := db.QueryRow(database.ConvertPlaceholders(`
 			SELECT COUNT(*) FROM ticket
-			WHERE customer_user_id = ?
+			WHERE customer_user_id = ?`+queueFilter+`
 			AND ticket_state_id IN (SELECT id FROM ticket_state WHERE type_id IN (1, 2))
-		`), username)
+		`), ticketArgs...)
 		_ = row.Scan(&stats.OpenTickets) //nolint:errcheck // Count defaults to 0
 
 		// Count closed tickets for this customer
 		row = db.QueryRow(database.ConvertPlaceholders(`
 			SELECT COUNT(*) FROM ticket
-			WHERE customer_user_id = ?
+			WHERE customer_user_id = ?`+queueFilter+`
 			AND ticket_state_id IN (SELECT id FROM ticket_state WHERE type_id = 3)
-		`), username)
+		`), ticketArgs...)
 		_ = row.Scan(&stats.ClosedTickets) //nolint:errcheck // Count defaults to 0
 
 		stats.TotalTickets = stats.OpenTickets + stats.ClosedTickets
@@ -186,8 +192,8 @@ func handleCustomerDashboard(db *sql.DB) gin.HandlerFunc {
 		var lastDate sql.NullTime
 		row = db.QueryRow(database.ConvertPlaceholders(`
 			SELECT MAX(create_time) FROM ticket
-			WHERE customer_user_id = ?
-		`), username)
+			WHERE customer_user_id = ?`+queueFilter+`
+		`), ticketArgs...)
 		_ = row.Scan(&lastDate) //nolint:errcheck // Defaults to null
 		if lastDate.Valid {
 			stats.LastTicketDate = lastDate.Time
@@ -199,7 +205,7 @@ func handleCustomerDashboard(db *sql.DB) gin.HandlerFunc {
 		// ? = userID (for unread_count subquery), ? = username (for WHERE clause)
 		rows, err := db.Query(database.ConvertPlaceholders(`
 			SELECT t.id, t.tn, t.title,
-				   ts.name as state,
+				   t.ticket_state_id,
 				   tp.name as priority,
 				   CASE
 				       WHEN tp.name LIKE '%very low%' THEN '#03c4f0'
@@ -214,24 +220,24 @@ func handleCustomerDashboard(db *sql.DB) gin.HandlerFunc {
 				   (SELECT COUNT(*) FROM article WHERE ticket_id = t.id AND is_visible_for_customer = 1) as article_count,
 				   0 as unread_count
 			FROM ticket t
-			LEFT JOIN ticket_state ts ON t.ticket_state_id = ts.id
 			LEFT JOIN ticket_priority tp ON t.ticket_priority_id = tp.id
-			WHERE t.customer_user_id = ?
+			WHERE t.customer_user_id = ?`+recentFilter+`
 			ORDER BY t.create_time DESC
 			LIMIT 10
-		`), username)
+		`), ticketArgs...)
 		if err != nil {
 			log.Printf("handleCustomerDashboard: query error: %v", err)
 		}
 		defer rows.Close()
 
+		states := loadCustomerStates(c, db)
 		recentTickets := []map[string]interface{}{}
 		for rows.Next() {
 			var ticket struct {
 				ID            int
 				TN            string
 				Title         string
-				State         string
+				StateID       int
 				Priority      string
 				PriorityColor sql.NullString
 				CreateTime    time.Time
@@ -239,17 +245,16 @@ func handleCustomerDashboard(db *sql.DB) gin.HandlerFunc {
 				ArticleCount  int
 				UnreadCount   int
 			}
-			if err := rows.Scan(&ticket.ID, &ticket.TN, &ticket.Title, &ticket.State,
+			if err := rows.Scan(&ticket.ID, &ticket.TN, &ticket.Title, &ticket.StateID,
 				&ticket.Priority, &ticket.PriorityColor, &ticket.CreateTime,
 				&ticket.ChangeTime, &ticket.ArticleCount, &ticket.UnreadCount); err != nil {
 				continue
 			}
 
-			recentTickets = append(recentTickets, map[string]interface{}{
+			item := map[string]interface{}{
 				"id":             ticket.ID,
 				"tn":             ticket.TN,
 				"title":          ticket.Title,
-				"state":          ticket.State,
 				"priority":       ticket.Priority,
 				"priority_color": ticket.PriorityColor.String,
 				"age":            formatAge(ticket.CreateTime),
@@ -258,7 +263,9 @@ func handleCustomerDashboard(db *sql.DB) gin.HandlerFunc {
 				"updated_at_iso": ticket.ChangeTime.UTC().Format(time.RFC3339),
 				"article_count":  ticket.ArticleCount,
 				"unread_count":   ticket.UnreadCount,
-			})
+			}
+			states.apply(item, "state", ticket.StateID)
+			recentTickets = append(recentTickets, item)
 		}
 		_ = rows.Err() //nolint:errcheck // Iteration errors don't affect UI
 
@@ -292,7 +299,7 @@ func handleCustomerTickets(db *sql.DB) gin.HandlerFunc {
 		// Build query
 		query := `
 			SELECT t.id, t.tn, t.title,
-				   ts.name as state,
+				   t.ticket_state_id,
 				   tp.name as priority,
 				   CASE
 				       WHEN tp.name LIKE '%very low%' THEN '#03c4f0'
@@ -308,7 +315,6 @@ func handleCustomerTickets(db *sql.DB) gin.HandlerFunc {
 				   (SELECT COUNT(*) FROM article WHERE ticket_id = t.id AND is_visible_for_customer = 1) as article_count,
 				   0 as unread_count
 			FROM ticket t
-			LEFT JOIN ticket_state ts ON t.ticket_state_id = ts.id
 			LEFT JOIN ticket_priority tp ON t.ticket_priority_id = tp.id
 			LEFT JOIN service s ON t.service_id = s.id
 			WHERE t.customer_user_id = ?
@@ -316,6 +322,10 @@ func handleCustomerTickets(db *sql.DB) gin.HandlerFunc {
 
 		args := []interface{}{username}
 
+		queueFilter, queueArgs := portalQueueFilter(c, "t.queue_id")
+		query += queueFilter
+		args = append(args, queueArgs...)
+
 		// Apply status filter
 		if status == "open" {
 			query += " AND t.ticket_state_id IN (SELECT id FROM ticket_state WHERE type_id IN (1, 2))"
@@ -342,13 +352,14 @@ func handleCustomerTickets(db *sql.DB) gin.HandlerFunc {
 		}
 		defer rows.Close()
 
+		states := loadCustomerStates(c, db)
 		tickets := []map[string]interface{}{}
 		for rows.Next() {
 			var ticket struct {
 				ID            int
 				TN            string
 				Title         string
-				State         string
+				StateID       int
 				Priority      string
 				PriorityColor sql.NullString
 				Service       sql.NullString
@@ -358,7 +369,7 @@ func handleCustomerTickets(db *sql.DB) gin.HandlerFunc {
 				UnreadCount   int
 			}
 
-			err := rows.Scan(&ticket.ID, &ticket.TN, &ticket.Title, &ticket.State,
+			err := rows.Scan(&ticket.ID, &ticket.TN, &ticket.Title, &ticket.StateID,
 				&ticket.Priority, &ticket.PriorityColor, &ticket.Service,
 				&ticket.CreateTime, &ticket.ChangeTime, &ticket.ArticleCount,
 				&ticket.UnreadCount)
@@ -367,11 +378,10 @@ func handleCustomerTickets(db *sql.DB) gin.HandlerFunc {
 				continue
 			}
 
-			tickets = append(tickets, map[string]interface{}{
+			item := map[string]interface{}{
 				"id":             ticket.ID,
 				"tn":             ticket.TN,
 				"title":          ticket.Title,
-				"state":          ticket.State,
 				"priority":       ticket.Priority,
 				"priority_color": ticket.PriorityColor.String,
 				"service":        ticket.Service.String,
@@ -381,7 +391,9 @@ func handleCustomerTickets(db *sql.DB) gin.HandlerFunc {
 				"updated_at_iso": ticket.ChangeTime.UTC().Format(time.RFC3339),
 				"article_count":  ticket.ArticleCount,
 				"unread_count":   ticket.UnreadCount,
-			})
+			}
+			states.apply(item, "state", ticket.StateID)
+			tickets = append(tickets, item)
 		}
 		_ = rows.Err() //nolint:errcheck // Iteration errors don't affect UI
 
@@ -559,6 +571,11 @@ func handleCustomerCreateTicket(db *sql.DB) gin.HandlerFunc {
 		if priorityID == "" {
 			priorityID = "3" // Normal priority
 		}
+		queueID := 1
+		if d := portalDomainForRequest(c); d != nil && len(d.QueueIDs) > 0 {
+			// Tickets opened on a restricted portal go to its first queue
+			queueID = d.QueueIDs[0]
+		}
 
 		// Create ticket
 		var ticketID int64
@@ -582,7 +599,7 @@ func handleCustomerCreateTicket(db *sql.DB) gin.HandlerFunc {
 				escalation_time, escalation_update_time, escalation_response_time, escalation_solution_time,
 				create_time, create_by, change_time, change_by
 			) VALUES (
-				?, ?, 1, 1, ?,
+				?, ?, ?, 1, ?,
 				1, ?, 1,
 				?, ?,
 				?, ?,
@@ -590,7 +607,7 @@ func handleCustomerCreateTicket(db *sql.DB) gin.HandlerFunc {
 				0, 0, 0, 0,
 				NOW(), ?, NOW(), ?
 			)
-		`, typeColumn)), tn, title, serviceIDVal, priorityID, systemUserID, systemUserID, customerID, username, systemUserID, systemUserID)
+		`, typeColumn)), tn, title, queueID, serviceIDVal, priorityID, systemUserID, systemUserID, customerID, username, systemUserID, systemUserID)
 
 		if err != nil {
 			log.Printf("Customer create ticket error: %v", err)
@@ -692,6 +709,7 @@ func handleCustomerCreateTicket(db *sql.DB) gin.HandlerFunc {
 					ticketID:  int(ticketID),
 					articleID: int(articleID),
 					userID:    systemUserID,
+					role:      service.AttachmentRoleCustomer,
 				})
 			}
 		}
@@ -710,13 +728,13 @@ func handleCustomerTicketView(db *sql.DB) gin.HandlerFunc {
 		ticketID := c.Param("id")
 		username := c.GetString("username")
 		cfg := customerPortalConfigFromContext(c, db)
+		queueFilter, queueArgs := portalQueueFilter(c, "t.queue_id")
 
 		// Get ticket details - ensure customer owns this ticket
 		var ticket struct {
 			ID            int
 			TN            string
 			Title         string
-			State         string
 			StateID       int
 			StateTypeID   int
 			Priority      string
@@ -731,7 +749,7 @@ func handleCustomerTicketView(db *sql.DB) gin.HandlerFunc {
 
 		err := db.QueryRow(database.ConvertPlaceholders(`
 			SELECT t.id, t.tn, t.title,
-			       ts.name as state, ts.id as state_id, ts.type_id as state_type_id,
+			       ts.id as state_id, ts.type_id as state_type_id,
 			       tp.name as priority,
 				   CASE
 				       WHEN tp.name LIKE '%very low%' THEN '#03c4f0'
@@ -753,10 +771,10 @@ func handleCustomerTicketView(db *sql.DB) gin.HandlerFunc {
 			LEFT JOIN queue q ON t.queue_id = q.id
 			LEFT JOIN users ou ON t.user_id = ou.id
 			LEFT JOIN users ru ON t.responsible_user_id = ru.id
-			WHERE t.id = ? AND t.customer_user_id = ?
-		`), ticketID, username).Scan(
+			WHERE t.id = ? AND t.customer_user_id = ?`+queueFilter+`
+		`), append([]interface{}{ticketID, username}, queueArgs...)...).Scan(
 			&ticket.ID, &ticket.TN, &ticket.Title,
-			&ticket.State, &ticket.StateID, &ticket.StateTypeID,
+			&ticket.StateID, &ticket.StateTypeID,
 			&ticket.Priority, &ticket.PriorityColor,
 			&ticket.Service, &ticket.Queue,
 			&ticket.Owner, &ticket.Responsible,
@@ -822,7 +840,7 @@ func handleCustomerTicketView(db *sql.DB) gin.HandlerFunc {
 				articles = append(articles, map[string]interface{}{
 					"id":          article.ID,
 					"subject":     article.Subject.String,
-					"body":        article.Body.String,
+					"body":        storage.DecodeText(article.Body.String),
 					"author":      author,
 					"is_customer": isCustomer,
 					"created":     formatAge(article.CreateTime),
@@ -857,27 +875,29 @@ func handleCustomerTicketView(db *sql.DB) gin.HandlerFunc {
 			replyArticleDynamicFields = replyDFs
 		}
 
+		ticketData := map[string]interface{}{
+			"id":             ticket.ID,
+			"tn":             ticket.TN,
+			"title":          ticket.Title,
+			"state_id":       ticket.StateID,
+			"priority":       ticket.Priority,
+			"priority_color": ticket.PriorityColor.String,
+			"service":        ticket.Service.String,
+			"queue":          ticket.Queue,
+			"owner":          ticket.Owner.String,
+			"responsible":    ticket.Responsible.String,
+			"age":            formatAge(ticket.CreateTime),
+			"created_at_iso": ticket.CreateTime.UTC().Format(time.RFC3339),
+			"last_changed":   formatAge(ticket.ChangeTime),
+			"updated_at_iso": ticket.ChangeTime.UTC().Format(time.RFC3339),
+			"can_close":      canClose,
+		}
+		loadCustomerStates(c, db).apply(ticketData, "state", ticket.StateID)
+
 		getPongo2Renderer().HTML(c, http.StatusOK, "pages/customer/ticket_view.pongo2", withPortalContextAndCustomer(pongo2.Context{
-			"Title":      fmt.Sprintf("%s - Ticket #%s", cfg.Title, ticket.TN),
-			"ActivePage": "customer",
-			"Ticket": map[string]interface{}{
-				"id":             ticket.ID,
-				"tn":             ticket.TN,
-				"title":          ticket.Title,
-				"state":          ticket.State,
-				"state_id":       ticket.StateID,
-				"priority":       ticket.Priority,
-				"priority_color": ticket.PriorityColor.String,
-				"service":        ticket.Service.String,
-				"queue":          ticket.Queue,
-				"owner":          ticket.Owner.String,
-				"responsible":    ticket.Responsible.String,
-				"age":            formatAge(ticket.CreateTime),
-				"created_at_iso": ticket.CreateTime.UTC().Format(time.RFC3339),
-				"last_changed":   formatAge(ticket.ChangeTime),
-				"updated_at_iso": ticket.ChangeTime.UTC().Format(time.RFC3339),
-				"can_close":      canClose,
-			},
+			"Title":                     fmt.Sprintf("%s - Ticket #%s", cfg.Title, ticket.TN),
+			"ActivePage":                "customer",
+			"Ticket":                    ticketData,
 			"Articles":                  articles,
 			"DynamicFields":             dynamicFieldsDisplay,
 			"ReplyArticleDynamicFields": replyArticleDynamicFields,
@@ -896,13 +916,14 @@ func handleCustomerTicketReply(db *sql.DB) gin.HandlerFunc {
 		systemUserID := 1
 
 		// Verify customer owns this ticket
+		queueFilter, queueArgs := portalQueueFilter(c, "queue_id")
 		var exists bool
 		err := db.QueryRow(database.ConvertPlaceholders(`
 			SELECT EXISTS(
 				SELECT 1 FROM ticket
-				WHERE id = ? AND customer_user_id = ?
+				WHERE id = ? AND customer_user_id = ?`+queueFilter+`
 			)
-		`), ticketID, username).Scan(&exists)
+		`), append([]interface{}{ticketID, username}, queueArgs...)...).Scan(&exists)
 
 		if err != nil || !exists {
 			c.JSON(http.StatusForbidden, gin.H{"error": "Access denied"})
@@ -984,6 +1005,7 @@ func handleCustomerTicketReply(db *sql.DB) gin.HandlerFunc {
 					ticketID:  ticketIDInt,
 					articleID: int(articleID),
 					userID:    systemUserID,
+					role:      service.AttachmentRoleCustomer,
 				})
 			}
 		}
@@ -1012,11 +1034,12 @@ func handleCustomerCloseTicket(db *sql.DB) gin.HandlerFunc {
 		systemUserID := 1
 
 		// Verify customer owns this ticket and it's not already closed
+		queueFilter, queueArgs := portalQueueFilter(c, "queue_id")
 		var stateID int
 		err := db.QueryRow(database.ConvertPlaceholders(`
 			SELECT ticket_state_id FROM ticket
-			WHERE id = ? AND customer_user_id = ?
-		`), ticketID, username).Scan(&stateID)
+			WHERE id = ? AND customer_user_id = ?`+queueFilter+`
+		`), append([]interface{}{ticketID, username}, queueArgs...)...).Scan(&stateID)
 
 		if err != nil {
 			if err == sql.ErrNoRows {
@@ -1386,9 +1409,10 @@ func handleCustomerPasswordForm(db *sql.DB) gin.HandlerFunc {
 		}
 
 		getPongo2Renderer().HTML(c, http.StatusOK, "pages/customer/password_form.pongo2", withPortalContextAndCustomer(pongo2.Context{
-			"Title":      fmt.Sprintf("%s - %s", cfg.Title, "Change Password"),
-			"ActivePage": "profile",
-			"Policy":     policy,
+			"Title":           fmt.Sprintf("%s - %s", cfg.Title, "Change Password"),
+			"ActivePage":      "profile",
+			"Policy":          policy,
+			"PasswordExpired": c.Query("expired") == "1",
 		}, cfg, db, username))
 	}
 }
@@ -1466,18 +1490,22 @@ func handleCustomerChangePassword(db *sql.DB) gin.HandlerFunc {
 			return
 		}
 
-		// Load and validate against password policy
-		policy, err := sysconfig.LoadCustomerPasswordPolicy(db)
+		// Validate against the password policy, history and breaches
+		policyService := service.NewPasswordPolicyService(db)
+		validationErr, err := policyService.CheckPassword(c.Request.Context(), service.PasswordAccountCustomer, username, request.NewPassword)
 		if err != nil {
-			log.Printf("Error loading customer password policy: %v", err)
-			// Continue with default policy
-			policy = sysconfig.DefaultCustomerPasswordPolicy()
+			log.Printf("Error checking customer password policy: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"error":   "Failed to check password policy",
+			})
+			return
 		}
-
-		if validationErr := policy.ValidatePassword(request.NewPassword); validationErr != nil {
+		if validationErr != nil {
 			c.JSON(http.StatusBadRequest, gin.H{
 				"success": false,
 				"error":   getPasswordPolicyErrorMessage(validationErr.Code),
+				"code":    validationErr.Code,
 			})
 			return
 		}
@@ -1509,6 +1537,10 @@ func handleCustomerChangePassword(db *sql.DB) gin.HandlerFunc {
 			return
 		}
 
+		if err := policyService.RecordChange(c.Request.Context(), service.PasswordAccountCustomer, username, newHash); err != nil {
+			log.Printf("Error recording password history for customer %s: %v", username, err)
+		}
+
 		c.JSON(http.StatusOK, gin.H{
 			"success": true,
 			"message": "Password changed successfully",
@@ -1529,6 +1561,10 @@ func getPasswordPolicyErrorMessage(code string) string {
 		return "Password must contain at least 1 number"
 	case "min_2_characters":
 		return "Password must contain at least 2 letters"
+	case "history":
+		return "Password was used recently, please choose a different one"
+	case "breached":
+		return "Password appears in a known data breach, please choose a different one"
 	default:
 		return "Password does not meet the security requirements"
 	}
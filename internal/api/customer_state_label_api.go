@@ -0,0 +1,191 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/i18n"
+	"github.com/goatkit/goatflow/internal/middleware"
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/service"
+)
+
+// customerStateLabelRequest is the JSON body accepted by the admin label
+// update handler.
+type customerStateLabelRequest struct {
+	Label   string `json:"label" binding:"required"`
+	I18nKey string `json:"i18n_key"`
+}
+
+// customerStates translates ticket states into what customers see, in the
+// language of the request.
+type customerStates struct {
+	byID map[int]*models.CustomerStateMapping
+	lang string
+}
+
+// loadCustomerStates loads the customer state mapping for a request. When
+// loading fails every state shows as open rather than by its internal name.
+func loadCustomerStates(c *gin.Context, db *sql.DB) *customerStates {
+	byID, err := service.NewCustomerStateLabelService(db).Mappings(c.Request.Context())
+	if err != nil {
+		log.Printf("customer state labels: loading failed: %v", err)
+	}
+	return &customerStates{byID: byID, lang: middleware.GetLanguage(c)}
+}
+
+// lookup returns the customer-facing label, i18n key and category of a
+// state. Labels with an i18n key are translated when the key exists.
+func (s *customerStates) lookup(stateID int) (label, key, category string) {
+	mapping, ok := s.byID[stateID]
+	if !ok {
+		def := service.DefaultCustomerState("")
+		mapping = &def
+	}
+	label = mapping.Label
+	if mapping.I18nKey != "" {
+		if translated := i18n.GetInstance().T(s.lang, mapping.I18nKey); translated != mapping.I18nKey {
+			label = translated
+		}
+	}
+	return label, mapping.I18nKey, mapping.Category
+}
+
+// apply replaces the internal state name under nameKey in a ticket
+// response with the customer-facing label, and adds the state's i18n key
+// and category.
+func (s *customerStates) apply(data map[string]interface{}, nameKey string, stateID int) {
+	label, key, category := s.lookup(stateID)
+	data[nameKey] = label
+	data["state_key"] = key
+	data["state_category"] = category
+}
+
+// customerStateLabelService returns the service, writing 503 when the
+// database is unavailable.
+func customerStateLabelService(c *gin.Context) *service.CustomerStateLabelService {
+	db, err := database.GetDB()
+	if err != nil || db == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"success": false, "error": "Database unavailable"})
+		return nil
+	}
+	return service.NewCustomerStateLabelService(db)
+}
+
+// customerStateLabelError maps CustomerStateLabelService errors to
+// responses.
+func customerStateLabelError(c *gin.Context, err error, action string) {
+	switch {
+	case errors.Is(err, service.ErrCustomerStateNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "State not found"})
+	case errors.Is(err, service.ErrCustomerStateLabelNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "No customer label configured for this state"})
+	case errors.Is(err, service.ErrCustomerStateLabelInvalid):
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+	default:
+		log.Printf("customer state label api: %s failed: %v", action, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to " + action})
+	}
+}
+
+// customerStateID parses the :state_id path parameter, writing 400 when it
+// is invalid.
+func customerStateID(c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.Param("state_id"))
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid state ID"})
+		return 0, false
+	}
+	return id, true
+}
+
+// HandleAdminListCustomerStateLabelsAPI handles GET /api/v1/admin/customer-state-labels.
+//
+//	@Summary		List customer-facing state labels
+//	@Description	Lists every ticket state with the label customers see: the configured one, or the default for the state type.
+//	@Tags			Customer State Labels
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}	"State mappings"
+//	@Security		BearerAuth
+//	@Router			/admin/customer-state-labels [get]
+func HandleAdminListCustomerStateLabelsAPI(c *gin.Context) {
+	svc := customerStateLabelService(c)
+	if svc == nil {
+		return
+	}
+	states, err := svc.List(c.Request.Context())
+	if err != nil {
+		customerStateLabelError(c, err, "load customer state labels")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": states})
+}
+
+// HandleSetCustomerStateLabelAPI handles PUT /api/v1/admin/customer-state-labels/:state_id.
+//
+//	@Summary		Set customer-facing state label
+//	@Description	Sets the label customers see for a ticket state. An i18n_key is translated in place of the label where the key exists.
+//	@Tags			Customer State Labels
+//	@Accept			json
+//	@Produce		json
+//	@Param			state_id	path		int		true	"Ticket state ID"
+//	@Param			label		body		object	true	"Label (label, i18n_key)"
+//	@Success		200			{object}	map[string]interface{}	"Label set"
+//	@Failure		400			{object}	map[string]interface{}	"Invalid request"
+//	@Failure		404			{object}	map[string]interface{}	"State not found"
+//	@Security		BearerAuth
+//	@Router			/admin/customer-state-labels/{state_id} [put]
+func HandleSetCustomerStateLabelAPI(c *gin.Context) {
+	stateID, ok := customerStateID(c)
+	if !ok {
+		return
+	}
+	var req customerStateLabelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid label request: " + err.Error()})
+		return
+	}
+	svc := customerStateLabelService(c)
+	if svc == nil {
+		return
+	}
+	label := &models.CustomerStateLabel{StateID: stateID, Label: req.Label, I18nKey: req.I18nKey}
+	if err := svc.SetLabel(c.Request.Context(), label, GetUserIDFromCtx(c, 1)); err != nil {
+		customerStateLabelError(c, err, "set customer state label")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": label})
+}
+
+// HandleDeleteCustomerStateLabelAPI handles DELETE /api/v1/admin/customer-state-labels/:state_id.
+//
+//	@Summary		Remove customer-facing state label
+//	@Description	Removes the configured label; customers see the default for the state type again.
+//	@Tags			Customer State Labels
+//	@Produce		json
+//	@Param			state_id	path		int	true	"Ticket state ID"
+//	@Success		200			{object}	map[string]interface{}	"Label removed"
+//	@Failure		404			{object}	map[string]interface{}	"No label configured"
+//	@Security		BearerAuth
+//	@Router			/admin/customer-state-labels/{state_id} [delete]
+func HandleDeleteCustomerStateLabelAPI(c *gin.Context) {
+	stateID, ok := customerStateID(c)
+	if !ok {
+		return
+	}
+	svc := customerStateLabelService(c)
+	if svc == nil {
+		return
+	}
+	if err := svc.DeleteLabel(c.Request.Context(), stateID); err != nil {
+		customerStateLabelError(c, err, "remove customer state label")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/service"
+	"github.com/goatkit/goatflow/internal/testutil"
+)
+
+func TestCustomerStateLabelHandlers_InvalidRequest(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("user_id", 1)
+		c.Next()
+	})
+	router.PUT("/api/v1/admin/customer-state-labels/:state_id", HandleSetCustomerStateLabelAPI)
+	router.DELETE("/api/v1/admin/customer-state-labels/:state_id", HandleDeleteCustomerStateLabelAPI)
+
+	for _, tc := range []struct {
+		method string
+		path   string
+		body   string
+		want   string
+	}{
+		{http.MethodPut, "/api/v1/admin/customer-state-labels/0", `{"label": "Waiting"}`, "Invalid state ID"},
+		{http.MethodPut, "/api/v1/admin/customer-state-labels/2", `{"i18n_key": "status.waiting"}`, "Invalid label request"},
+		{http.MethodDelete, "/api/v1/admin/customer-state-labels/abc", "", "Invalid state ID"},
+	} {
+		t.Run(tc.method+" "+tc.path+" "+tc.body, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Contains(t, w.Body.String(), tc.want)
+		})
+	}
+}
+
+func TestCustomerStatesApply(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := testutil.UseMigratedDB(t)
+	_, err := db.Exec(`INSERT INTO users (id, login, pw, first_name, last_name, valid_id, create_time, create_by, change_time, change_by)
+		VALUES (1, 'root@localhost', 'x', 'Admin', 'OTRS', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	svc := service.NewCustomerStateLabelService(db)
+	states, err := svc.List(ctx)
+	require.NoError(t, err)
+	require.NotEmpty(t, states)
+	labelled, plain := states[0], states[len(states)-1]
+	require.NoError(t, svc.SetLabel(ctx, &models.CustomerStateLabel{
+		StateID: labelled.StateID, Label: "Received", I18nKey: "customer.state.no_such_key"}, 1))
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/customer/tickets", nil)
+	customer := loadCustomerStates(c, db)
+
+	data := map[string]interface{}{"state": labelled.StateName}
+	customer.apply(data, "state", labelled.StateID)
+	assert.Equal(t, "Received", data["state"], "untranslated keys fall back to the label")
+	assert.Equal(t, "customer.state.no_such_key", data["state_key"])
+	assert.Equal(t, labelled.Category, data["state_category"])
+
+	data = map[string]interface{}{"state_name": plain.StateName}
+	customer.apply(data, "state_name", plain.StateID)
+	assert.NotEqual(t, plain.StateName, data["state_name"], "internal names are replaced")
+	assert.Equal(t, service.DefaultCustomerState(plain.StateType).I18nKey, data["state_key"])
+
+	data = map[string]interface{}{}
+	customer.apply(data, "state", 9999)
+	assert.Equal(t, models.CustomerStateOpen, data["state_category"], "unknown states show as open")
+}
@@ -0,0 +1,136 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/flosch/pongo2/v6"
+	"github.com/gin-gonic/gin"
+
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/service"
+)
+
+// customerSurveyQuestion is a survey question with the answers offered on
+// the survey page and the customer's previous input.
+type customerSurveyQuestion struct {
+	models.SurveyQuestion
+	Choices []string
+	Value   string
+}
+
+// customerSurveyQuestions lists the questions of a survey for the survey
+// page: the scale of rating and NPS questions, the options of choice
+// questions.
+func customerSurveyQuestions(survey *models.Survey, values map[string]string) []customerSurveyQuestion {
+	questions := make([]customerSurveyQuestion, 0, len(survey.Questions))
+	for _, q := range survey.Questions {
+		view := customerSurveyQuestion{SurveyQuestion: q, Value: values[q.ID]}
+		switch q.Type {
+		case models.SurveyQuestionRating, models.SurveyQuestionNPS:
+			lo, hi := q.Type.Scale()
+			for n := lo; n <= hi; n++ {
+				view.Choices = append(view.Choices, strconv.Itoa(n))
+			}
+		case models.SurveyQuestionChoice:
+			view.Choices = q.Options
+		}
+		questions = append(questions, view)
+	}
+	return questions
+}
+
+// renderCustomerSurvey renders the survey page. Without a survey it shows
+// only the message for state: thanks, answered, expired or invalid.
+func renderCustomerSurvey(c *gin.Context, status int, state string, ctx pongo2.Context) {
+	if ctx == nil {
+		ctx = pongo2.Context{}
+	}
+	ctx["State"] = state
+	if db, err := database.GetDB(); err == nil && db != nil {
+		ctx = withPortalContext(ctx, customerPortalConfigFromContext(c, db))
+	}
+	getPongo2Renderer().HTML(c, status, "pages/customer/survey.pongo2", ctx)
+}
+
+// customerSurveyOpenError renders the page for a survey link that cannot
+// be answered.
+func customerSurveyOpenError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, service.ErrSurveyAnswered):
+		renderCustomerSurvey(c, http.StatusOK, "answered", nil)
+	case errors.Is(err, service.ErrSurveyLinkExpired):
+		renderCustomerSurvey(c, http.StatusGone, "expired", nil)
+	case errors.Is(err, service.ErrSurveyLinkInvalid):
+		renderCustomerSurvey(c, http.StatusNotFound, "invalid", nil)
+	default:
+		log.Printf("customer survey: opening survey failed: %v", err)
+		renderCustomerSurvey(c, http.StatusInternalServerError, "invalid", nil)
+	}
+}
+
+// handleCustomerSurvey shows the survey behind an emailed link. The link's
+// token identifies the ticket, so no login is needed.
+func handleCustomerSurvey(c *gin.Context) {
+	db, err := database.GetDB()
+	if err != nil || db == nil {
+		c.String(http.StatusServiceUnavailable, "Service unavailable")
+		return
+	}
+	survey, req, err := service.NewSurveyService(db).Open(c.Request.Context(), c.Param("token"))
+	if err != nil {
+		customerSurveyOpenError(c, err)
+		return
+	}
+	renderCustomerSurvey(c, http.StatusOK, "form", pongo2.Context{
+		"Survey":       survey,
+		"Questions":    customerSurveyQuestions(survey, nil),
+		"TicketNumber": req.TicketNumber,
+		"Token":        c.Param("token"),
+	})
+}
+
+// handleCustomerSurveySubmit records the answers to a survey. Invalid
+// answers show the form again with the customer's input.
+func handleCustomerSurveySubmit(c *gin.Context) {
+	db, err := database.GetDB()
+	if err != nil || db == nil {
+		c.String(http.StatusServiceUnavailable, "Service unavailable")
+		return
+	}
+	if err := c.Request.ParseForm(); err != nil {
+		c.String(http.StatusBadRequest, "Invalid form")
+		return
+	}
+	values := make(map[string]string, len(c.Request.PostForm))
+	for name := range c.Request.PostForm {
+		values[name] = c.Request.PostForm.Get(name)
+	}
+
+	svc := service.NewSurveyService(db)
+	token := c.Param("token")
+	err = svc.Submit(c.Request.Context(), token, values)
+	if err == nil {
+		renderCustomerSurvey(c, http.StatusOK, "thanks", nil)
+		return
+	}
+	if !errors.Is(err, service.ErrSurveyAnswer) {
+		customerSurveyOpenError(c, err)
+		return
+	}
+	survey, req, openErr := svc.Open(c.Request.Context(), token)
+	if openErr != nil {
+		customerSurveyOpenError(c, openErr)
+		return
+	}
+	renderCustomerSurvey(c, http.StatusBadRequest, "form", pongo2.Context{
+		"Survey":       survey,
+		"Questions":    customerSurveyQuestions(survey, values),
+		"TicketNumber": req.TicketNumber,
+		"Token":        token,
+		"error":        err.Error(),
+	})
+}
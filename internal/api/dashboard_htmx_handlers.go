@@ -51,7 +51,7 @@ func handleDashboard(c *gin.Context) {
 	}
 
 	// Get database connection through repository pattern (graceful fallback if unavailable)
-	db, err := database.GetDB()
+	db, err := database.GetReadDB()
 	if err != nil || db == nil {
 		getPongo2Renderer().HTML(c, http.StatusOK, "pages/dashboard.pongo2", pongo2.Context{
 			"Title":         "Dashboard - GoatFlow",
@@ -310,7 +310,7 @@ func buildTicketStatusOptions(db *sql.DB) ([]gin.H, bool) {
 
 // handleDashboardStats returns dashboard statistics.
 func handleDashboardStats(c *gin.Context) {
-	db, err := database.GetDB()
+	db, err := database.GetReadDB()
 	if err != nil || db == nil {
 		// Return JSON error when database is unavailable
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -426,7 +426,7 @@ func handleRecentTickets(c *gin.Context) {
 		return i18nInstance.T(lang, key)
 	}
 
-	db, err := database.GetDB()
+	db, err := database.GetReadDB()
 	if err != nil || db == nil {
 		// Return JSON error when database is unavailable
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -1,7 +1,9 @@
 package api
 
 import (
+	"log"
 	"net/http"
+	"net/url"
 	"sort"
 
 	"github.com/gin-gonic/gin"
@@ -16,8 +18,13 @@ func init() {
 	routing.RegisterHandler("handleDashboardWidgetsConfig", handleDashboardWidgetsConfig)
 	routing.RegisterHandler("handleDashboardWidgetsUpdate", handleDashboardWidgetsUpdate)
 	routing.RegisterHandler("handleDashboardWidgetsList", handleDashboardWidgetsList)
+	routing.RegisterHandler("handleDashboardPluginWidget", handleDashboardPluginWidget)
 }
 
+// htmxStopPolling is the status that makes htmx cancel an every-N-seconds
+// trigger.
+const htmxStopPolling = 286
+
 // WidgetInfo describes a widget for the configuration UI.
 type WidgetInfo struct {
 	ID         string `json:"id"`          // "plugin_name:widget_id"
@@ -153,6 +160,43 @@ func handleDashboardWidgetsUpdate(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Dashboard widgets updated"})
 }
 
+// handleDashboardPluginWidget returns a refreshable plugin widget's content
+// for the dashboard's htmx polling. The HTML comes from the host cache, so
+// polls within the widget's cache TTL never reach the plugin. Widgets that are
+// gone, disabled or no longer refreshable answer 286 to stop the polling; a
+// failed render answers 204 and the dashboard keeps the previous content.
+// GET /api/dashboard/plugin-widgets/:plugin/:id
+func handleDashboardPluginWidget(c *gin.Context) {
+	if pluginManager == nil {
+		c.Status(htmxStopPolling)
+		return
+	}
+	pluginName, widgetID := c.Param("plugin"), c.Param("id")
+	spec, ok := findPluginWidget(pluginName, widgetID)
+	if !ok || !spec.Refreshable || !pluginManager.IsEnabled(pluginName) {
+		c.Status(htmxStopPolling)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	if pluginManager.WidgetIsolation(pluginName) == plugin.WidgetIsolationIframe {
+		c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(renderPluginWidgetIframe(pluginName, widgetID, spec.Title)))
+		return
+	}
+	html, err := pluginManager.RenderWidget(pluginContextWithLanguage(c), pluginName, spec)
+	if err != nil {
+		log.Printf("🔌 Widget %s:%s refresh failed: %v", pluginName, widgetID, err)
+		c.Status(http.StatusNoContent)
+		return
+	}
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(html))
+}
+
+// pluginWidgetPollPath returns the URL the dashboard polls for a widget.
+func pluginWidgetPollPath(pluginName, widgetID string) string {
+	return "/api/dashboard/plugin-widgets/" + url.PathEscape(pluginName) + "/" + url.PathEscape(widgetID)
+}
+
 // getDashboardUserID extracts user ID from context for dashboard widgets.
 func getDashboardUserID(c *gin.Context) int {
 	if val, ok := c.Get("user_id"); ok {
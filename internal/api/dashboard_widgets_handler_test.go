@@ -0,0 +1,56 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/goatkit/goatflow/internal/plugin"
+	"github.com/goatkit/goatflow/internal/plugin/example"
+)
+
+func TestHandleDashboardPluginWidget(t *testing.T) {
+	mgr := plugin.NewManager(&mockHostAPI{})
+	if err := mgr.Register(context.Background(), example.NewHelloPlugin()); err != nil {
+		t.Fatalf("register failed: %v", err)
+	}
+	prev := pluginManager
+	SetPluginManager(mgr)
+	t.Cleanup(func() { SetPluginManager(prev) })
+
+	r := gin.New()
+	r.GET("/api/dashboard/plugin-widgets/:plugin/:id", handleDashboardPluginWidget)
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	widgets := GetPluginWidgets(context.Background(), "dashboard")
+	if len(widgets) != 1 || widgets[0].RefreshSec != 60 || widgets[0].PollURL != "/api/dashboard/plugin-widgets/hello/hello-widget" {
+		t.Fatalf("expected polled widget, got %+v", widgets)
+	}
+
+	w := get(widgets[0].PollURL)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Hello from the plugin") {
+		t.Errorf("expected widget HTML, got %d: %s", w.Code, w.Body.String())
+	}
+	if w.Header().Get("Cache-Control") != "no-store" {
+		t.Errorf("expected no-store, got %q", w.Header().Get("Cache-Control"))
+	}
+
+	if w := get("/api/dashboard/plugin-widgets/hello/missing"); w.Code != htmxStopPolling {
+		t.Errorf("expected 286 for unknown widget, got %d", w.Code)
+	}
+
+	if err := mgr.Disable("hello"); err != nil {
+		t.Fatal(err)
+	}
+	if w := get(widgets[0].PollURL); w.Code != htmxStopPolling {
+		t.Errorf("expected 286 for disabled plugin, got %d", w.Code)
+	}
+}
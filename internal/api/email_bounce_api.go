@@ -0,0 +1,177 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/service"
+	"github.com/goatkit/goatflow/internal/sysconfig"
+)
+
+// emailLoopService returns the service, writing 503 when the database is
+// unavailable.
+func emailLoopService(c *gin.Context) *service.EmailLoopService {
+	db, err := database.GetDB()
+	if err != nil || db == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"success": false, "error": "Database unavailable"})
+		return nil
+	}
+	return service.NewEmailLoopService(db)
+}
+
+// emailLoopError maps EmailLoopService errors to responses.
+func emailLoopError(c *gin.Context, err error, action string) {
+	switch {
+	case errors.Is(err, service.ErrEmailBounceNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Email bounce not found"})
+	case errors.Is(err, service.ErrEmailLoopInvalid):
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+	default:
+		log.Printf("email loop api: %s failed: %v", action, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to " + action})
+	}
+}
+
+// emailBounceParam returns the email path parameter, writing 400 when it is
+// not an address.
+func emailBounceParam(c *gin.Context) (string, bool) {
+	email := strings.TrimSpace(c.Param("email"))
+	if !strings.Contains(email, "@") {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid email address"})
+		return "", false
+	}
+	return email, true
+}
+
+// HandleListEmailBouncesAPI handles GET /api/v1/email-bounces.
+//
+//	@Summary		List email bounces
+//	@Description	Lists the addresses inbound bounce reports were received for, most recent first. Pass email (repeatable) to look up given addresses.
+//	@Tags			Email Bounces
+//	@Produce		json
+//	@Param			email	query		string	false	"Email address"
+//	@Success		200		{object}	map[string]interface{}	"Email bounces"
+//	@Security		BearerAuth
+//	@Router			/email-bounces [get]
+func HandleListEmailBouncesAPI(c *gin.Context) {
+	svc := emailLoopService(c)
+	if svc == nil {
+		return
+	}
+	bounces, err := svc.Bounces(c.Request.Context(), c.QueryArray("email")...)
+	if err != nil {
+		emailLoopError(c, err, "load email bounces")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": bounces})
+}
+
+// HandleGetEmailBounceAPI handles GET /api/v1/email-bounces/:email.
+//
+//	@Summary		Get email bounce
+//	@Description	Returns the bounce state of an address and whether auto responses to it are paused.
+//	@Tags			Email Bounces
+//	@Produce		json
+//	@Param			email	path		string	true	"Email address"
+//	@Success		200		{object}	map[string]interface{}	"Email bounce"
+//	@Failure		404		{object}	map[string]interface{}	"Not found"
+//	@Security		BearerAuth
+//	@Router			/email-bounces/{email} [get]
+func HandleGetEmailBounceAPI(c *gin.Context) {
+	email, ok := emailBounceParam(c)
+	if !ok {
+		return
+	}
+	svc := emailLoopService(c)
+	if svc == nil {
+		return
+	}
+	bounce, err := svc.Bounce(c.Request.Context(), email)
+	if err != nil {
+		emailLoopError(c, err, "load email bounce")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": bounce})
+}
+
+// HandleClearEmailBounceAPI handles DELETE /api/v1/email-bounces/:email.
+//
+//	@Summary		Clear email bounce
+//	@Description	Clears the bounce state of an address, resuming auto responses to it.
+//	@Tags			Email Bounces
+//	@Produce		json
+//	@Param			email	path		string	true	"Email address"
+//	@Success		200		{object}	map[string]interface{}	"Cleared"
+//	@Failure		404		{object}	map[string]interface{}	"Not found"
+//	@Security		BearerAuth
+//	@Router			/email-bounces/{email} [delete]
+func HandleClearEmailBounceAPI(c *gin.Context) {
+	email, ok := emailBounceParam(c)
+	if !ok {
+		return
+	}
+	svc := emailLoopService(c)
+	if svc == nil {
+		return
+	}
+	if err := svc.ClearBounce(c.Request.Context(), email); err != nil {
+		emailLoopError(c, err, "clear email bounce")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// HandleGetEmailLoopSettingsAPI handles GET /api/v1/admin/email-loop/settings.
+//
+//	@Summary		Get email loop settings
+//	@Tags			Email Bounces
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}	"Email loop settings"
+//	@Security		BearerAuth
+//	@Router			/admin/email-loop/settings [get]
+func HandleGetEmailLoopSettingsAPI(c *gin.Context) {
+	svc := emailLoopService(c)
+	if svc == nil {
+		return
+	}
+	cfg, err := svc.Settings()
+	if err != nil {
+		emailLoopError(c, err, "load email loop settings")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": cfg})
+}
+
+// HandleUpdateEmailLoopSettingsAPI handles PUT /api/v1/admin/email-loop/settings.
+//
+//	@Summary		Update email loop settings
+//	@Description	Sets the daily maximum of automatic emails per address (0 disables it) and the soft bounce limit.
+//	@Tags			Email Bounces
+//	@Accept			json
+//	@Produce		json
+//	@Param			settings	body		object	true	"max_emails_per_address, soft_bounce_limit"
+//	@Success		200			{object}	map[string]interface{}	"Email loop settings"
+//	@Failure		400			{object}	map[string]interface{}	"Invalid settings"
+//	@Security		BearerAuth
+//	@Router			/admin/email-loop/settings [put]
+func HandleUpdateEmailLoopSettingsAPI(c *gin.Context) {
+	var cfg sysconfig.EmailLoopConfig
+	if err := c.ShouldBindJSON(&cfg); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid request body"})
+		return
+	}
+	svc := emailLoopService(c)
+	if svc == nil {
+		return
+	}
+	if err := svc.SaveSettings(cfg, GetUserIDFromCtx(c, 1)); err != nil {
+		emailLoopError(c, err, "save email loop settings")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": cfg})
+}
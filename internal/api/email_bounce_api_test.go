@@ -0,0 +1,45 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEmailBounceHandlers_InvalidRequest(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("user_id", 1)
+		c.Next()
+	})
+	router.GET("/api/v1/email-bounces/:email", HandleGetEmailBounceAPI)
+	router.DELETE("/api/v1/email-bounces/:email", HandleClearEmailBounceAPI)
+	router.PUT("/api/v1/admin/email-loop/settings", HandleUpdateEmailLoopSettingsAPI)
+
+	for _, tc := range []struct {
+		method string
+		path   string
+		body   string
+		want   string
+	}{
+		{http.MethodGet, "/api/v1/email-bounces/jane", "", "Invalid email address"},
+		{http.MethodDelete, "/api/v1/email-bounces/%20", "", "Invalid email address"},
+		{http.MethodPut, "/api/v1/admin/email-loop/settings", `{"max_emails_per_address": "many"}`, "Invalid request body"},
+	} {
+		t.Run(tc.method+" "+tc.path+" "+tc.body, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Contains(t, w.Body.String(), tc.want)
+		})
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/gin-gonic/gin"
 
 	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/service"
 )
 
 type systemAddressDTO struct {
@@ -129,16 +130,30 @@ func handleAdminEmailIdentities(c *gin.Context) {
 		return
 	}
 
+	autoResponses := service.NewAutoResponseService(db)
+	responses, err := autoResponses.List(c.Request.Context())
+	if err != nil {
+		sendErrorResponse(c, http.StatusInternalServerError, "Failed to load auto responses")
+		return
+	}
+	responseTypes, err := autoResponses.Types(c.Request.Context())
+	if err != nil {
+		sendErrorResponse(c, http.StatusInternalServerError, "Failed to load auto response types")
+		return
+	}
+
 	tab := sanitizeEmailIdentityTab(c.Query("tab"))
 
 	getPongo2Renderer().HTML(c, http.StatusOK, "pages/admin/email_identities.pongo2", pongo2.Context{
-		"SystemAddresses": addresses,
-		"Salutations":     salutations,
-		"Signatures":      signatures,
-		"Queues":          queues,
-		"CurrentTab":      tab,
-		"ActivePage":      "admin",
-		"User":            getUserMapForTemplate(c),
+		"SystemAddresses":   addresses,
+		"Salutations":       salutations,
+		"Signatures":        signatures,
+		"AutoResponses":     responses,
+		"AutoResponseTypes": responseTypes,
+		"Queues":            queues,
+		"CurrentTab":        tab,
+		"ActivePage":        "admin",
+		"User":              getUserMapForTemplate(c),
 	})
 }
 
@@ -758,6 +773,8 @@ func sanitizeEmailIdentityTab(tab string) string {
 		return "salutations"
 	case "signatures":
 		return "signatures"
+	case "auto-responses":
+		return "auto-responses"
 	default:
 		return "system-addresses"
 	}
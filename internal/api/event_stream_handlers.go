@@ -0,0 +1,144 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/events"
+	"github.com/goatkit/goatflow/internal/service"
+)
+
+// eventStreamHeartbeat is how often an idle stream sends a heartbeat so
+// proxies keep the connection open.
+var eventStreamHeartbeat = 30 * time.Second
+
+// eventStreamQueueScope returns the queues an agent may receive ticket and
+// queue events for; all is true for admins. Tests replace it to avoid a
+// database.
+var eventStreamQueueScope = resolveEventStreamQueues
+
+// HandleEventStreamAPI handles GET /api/v1/events/stream.
+//
+//	@Summary		Stream real-time events
+//	@Description	Server-Sent Events with ticket changes, queue counters and plugin lifecycle events, limited to the queues the agent can read. Reconnecting clients send Last-Event-ID to receive missed events.
+//	@Tags			Events
+//	@Produce		text/event-stream
+//	@Param			types			query	string	false	"Comma-separated categories to receive: ticket, queue, plugin"
+//	@Param			Last-Event-ID	header	string	false	"Resume after this event ID"
+//	@Success		200	{string}	string	"Event stream"
+//	@Failure		403	{object}	map[string]interface{}	"Agent access required"
+//	@Security		BearerAuth
+//	@Router			/events/stream [get]
+func HandleEventStreamAPI(c *gin.Context) {
+	if kbIsCustomer(c) {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": "Agent access required"})
+		return
+	}
+
+	all := false
+	if isAdmin, _ := c.Get("isInAdminGroup"); isAdmin == true {
+		all = true
+	}
+	var queues map[int]bool
+	if !all {
+		var err error
+		all, queues, err = eventStreamQueueScope(c.Request.Context(), GetUserIDFromCtxUint(c, 0))
+		if err != nil {
+			log.Printf("event stream: resolving queue access failed: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to check permissions"})
+			return
+		}
+	}
+
+	var categories map[string]bool
+	if types := c.Query("types"); types != "" {
+		categories = make(map[string]bool)
+		for _, t := range strings.Split(types, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				categories[t] = true
+			}
+		}
+	}
+
+	allow := func(e events.Event) bool {
+		if categories != nil && !categories[e.Category()] {
+			return false
+		}
+		return e.QueueID == 0 || all || queues[e.QueueID]
+	}
+
+	lastID, _ := strconv.ParseUint(c.GetHeader("Last-Event-ID"), 10, 64)
+	sub := events.Default().Subscribe(allow, lastID)
+	defer sub.Close()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	_, _ = fmt.Fprint(c.Writer, "event: connected\ndata: {}\n\n") //nolint:errcheck // Best effort streaming
+	c.Writer.Flush()
+
+	ticker := time.NewTicker(eventStreamHeartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case e, ok := <-sub.Events():
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(e)
+			if err != nil {
+				continue
+			}
+			_, _ = fmt.Fprintf(c.Writer, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, data) //nolint:errcheck // Best effort streaming
+			c.Writer.Flush()
+		case <-ticker.C:
+			_, _ = fmt.Fprint(c.Writer, "event: heartbeat\ndata: {}\n\n") //nolint:errcheck // Best effort streaming
+			c.Writer.Flush()
+		case <-c.Request.Context().Done():
+			return
+		}
+	}
+}
+
+// resolveEventStreamQueues looks up the queues the agent has ro access to.
+// The scope is fixed for the life of the connection; clients pick up
+// permission changes when they reconnect.
+func resolveEventStreamQueues(ctx context.Context, userID uint) (bool, map[int]bool, error) {
+	if userID == 0 {
+		return false, nil, nil
+	}
+	db, err := database.GetDB()
+	if err != nil || db == nil {
+		return false, nil, fmt.Errorf("database unavailable: %w", err)
+	}
+
+	access := service.NewQueueAccessService(db)
+	isAdmin, err := access.IsAdmin(ctx, userID)
+	if err != nil {
+		return false, nil, err
+	}
+	if isAdmin {
+		return true, nil, nil
+	}
+	ids, err := access.GetAccessibleQueueIDs(ctx, userID, "ro")
+	if err != nil {
+		return false, nil, err
+	}
+	queues := make(map[int]bool, len(ids))
+	for _, id := range ids {
+		queues[int(id)] = true
+	}
+	return false, queues, nil
+}
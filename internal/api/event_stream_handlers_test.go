@@ -0,0 +1,125 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/events"
+)
+
+// streamEvents runs the stream handler, publishes evs once it has
+// subscribed, and returns the response body after disconnecting.
+func streamEvents(t *testing.T, target string, setup func(*gin.Context), evs ...events.Event) *httptest.ResponseRecorder {
+	t.Helper()
+	router := gin.New()
+	router.GET("/api/v1/events/stream", func(c *gin.Context) {
+		setup(c)
+		c.Next()
+	}, HandleEventStreamAPI)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req := httptest.NewRequest(http.MethodGet, target, nil).WithContext(ctx)
+	w := httptest.NewRecorder()
+
+	before := events.Default().Subscribers()
+	done := make(chan struct{})
+	go func() {
+		router.ServeHTTP(w, req)
+		close(done)
+	}()
+
+	subscribed := false
+	for i := 0; i < 200 && !subscribed; i++ {
+		select {
+		case <-done:
+			cancel()
+			return w
+		default:
+		}
+		subscribed = events.Default().Subscribers() > before
+		time.Sleep(5 * time.Millisecond)
+	}
+	require.True(t, subscribed, "handler did not subscribe")
+	for _, e := range evs {
+		events.Publish(e)
+	}
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	<-done
+	return w
+}
+
+func TestHandleEventStreamAPI_ScopesToQueues(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	orig := eventStreamQueueScope
+	defer func() { eventStreamQueueScope = orig }()
+	eventStreamQueueScope = func(ctx context.Context, userID uint) (bool, map[int]bool, error) {
+		assert.Equal(t, uint(7), userID)
+		return false, map[int]bool{1: true}, nil
+	}
+
+	w := streamEvents(t, "/api/v1/events/stream", func(c *gin.Context) {
+		c.Set("user_id", 7)
+		c.Set("user_role", "Agent")
+	},
+		events.Event{Type: events.TypeTicketUpdated, QueueID: 1, TicketID: 101},
+		events.Event{Type: events.TypeTicketUpdated, QueueID: 2, TicketID: 202},
+		events.Event{Type: events.TypeQueueCounts, QueueID: 2},
+		events.Event{Type: events.TypePluginEnabled, Plugin: "stats"},
+	)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/event-stream", w.Header().Get("Content-Type"))
+	body := w.Body.String()
+	assert.Contains(t, body, "event: connected")
+	assert.Contains(t, body, `"ticket_id":101`)
+	assert.Contains(t, body, "event: plugin.enabled")
+	assert.NotContains(t, body, `"ticket_id":202`)
+	assert.NotContains(t, body, "event: queue.counts")
+}
+
+func TestHandleEventStreamAPI_TypesFilter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	orig := eventStreamQueueScope
+	defer func() { eventStreamQueueScope = orig }()
+	eventStreamQueueScope = func(context.Context, uint) (bool, map[int]bool, error) {
+		t.Fatal("admins skip the queue lookup")
+		return false, nil, nil
+	}
+
+	w := streamEvents(t, "/api/v1/events/stream?types=queue", func(c *gin.Context) {
+		c.Set("user_id", 1)
+		c.Set("isInAdminGroup", true)
+	},
+		events.Event{Type: events.TypeTicketUpdated, QueueID: 3, TicketID: 303},
+		events.Event{Type: events.TypeQueueCounts, QueueID: 3, Data: events.QueueCounts{Open: 4, Total: 4}},
+	)
+
+	body := w.Body.String()
+	assert.Contains(t, body, "event: queue.counts")
+	assert.Contains(t, body, `"open":4`)
+	assert.NotContains(t, body, `"ticket_id":303`)
+}
+
+func TestHandleEventStreamAPI_RejectsCustomers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.GET("/api/v1/events/stream", func(c *gin.Context) {
+		c.Set("user_id", 9)
+		c.Set("is_customer", true)
+	}, HandleEventStreamAPI)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/events/stream", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
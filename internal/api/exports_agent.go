@@ -21,6 +21,19 @@ func wrapDBHandler(handlerFactory func(*sql.DB) gin.HandlerFunc) gin.HandlerFunc
 	}
 }
 
+// wrapReadDBHandler wraps a read-only handler factory, giving it a read
+// replica when one is available.
+func wrapReadDBHandler(handlerFactory func(*sql.DB) gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		db, err := database.GetReadDB()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database unavailable"})
+			return
+		}
+		handlerFactory(db)(c)
+	}
+}
+
 // AgentHandlerExports provides exported handler functions for agent routes.
 var AgentHandlerExports = struct {
 	HandleAgentTickets         gin.HandlerFunc
@@ -53,7 +66,7 @@ var AgentHandlerExports = struct {
 	HandleGetBulkActionOptions  gin.HandlerFunc
 	HandleGetFilteredTicketIds  gin.HandlerFunc
 }{
-	HandleAgentTickets:         wrapDBHandler(handleAgentTickets),
+	HandleAgentTickets:         wrapReadDBHandler(handleAgentTickets),
 	HandleAgentTicketReply:     wrapDBHandler(handleAgentTicketReply),
 	HandleAgentTicketNote:      wrapDBHandler(handleAgentTicketNote),
 	HandleAgentTicketPhone:     wrapDBHandler(handleAgentTicketPhone),
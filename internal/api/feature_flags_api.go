@@ -0,0 +1,246 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/flosch/pongo2/v6"
+	"github.com/gin-gonic/gin"
+
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/service"
+	"github.com/goatkit/goatflow/internal/sysconfig"
+)
+
+// featureFlagService returns the service, writing 503 when the database is
+// unavailable.
+func featureFlagService(c *gin.Context) *service.FeatureFlagService {
+	db, err := database.GetDB()
+	if err != nil || db == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"success": false, "error": "Database unavailable"})
+		return nil
+	}
+	return service.NewFeatureFlagService(db)
+}
+
+// featureFlagError maps FeatureFlagService errors to responses.
+func featureFlagError(c *gin.Context, err error, action string) {
+	switch {
+	case errors.Is(err, service.ErrFeatureFlagNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Feature flag not found"})
+	case errors.Is(err, service.ErrFeatureFlagExists):
+		c.JSON(http.StatusConflict, gin.H{"success": false, "error": "Feature flag already exists"})
+	case errors.Is(err, service.ErrFeatureFlagInvalid):
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+	default:
+		log.Printf("feature flags api: %s failed: %v", action, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to " + action})
+	}
+}
+
+// featureEnabled reports whether a feature flag is on for the current user.
+// Handlers use it to dark-launch features; any error counts as off.
+func featureEnabled(c *gin.Context, flag string) bool {
+	db, err := database.GetDB()
+	if err != nil || db == nil {
+		return false
+	}
+	on, err := service.NewFeatureFlagService(db).Enabled(c.Request.Context(), flag, GetUserIDFromCtx(c, 0))
+	if err != nil {
+		log.Printf("feature flags: evaluating %s failed: %v", flag, err)
+		return false
+	}
+	return on
+}
+
+// fetchFeatureFlagGroups returns the names of the valid groups flags can
+// target.
+func fetchFeatureFlagGroups(db *sql.DB) ([]string, error) {
+	rows, err := db.Query("SELECT name FROM groups WHERE valid_id = 1 ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
+// handleAdminFeatureFlags renders the feature flags page.
+func handleAdminFeatureFlags(c *gin.Context) {
+	if htmxHandlerSkipDB() || getPongo2Renderer() == nil || getPongo2Renderer().TemplateSet() == nil {
+		c.Data(http.StatusOK, "text/html; charset=utf-8", []byte("<main>Feature Flags</main>"))
+		return
+	}
+
+	db, err := database.GetDB()
+	if err != nil || db == nil {
+		sendErrorResponse(c, http.StatusInternalServerError, "Database connection failed")
+		return
+	}
+
+	groups, err := fetchFeatureFlagGroups(db)
+	if err != nil {
+		sendErrorResponse(c, http.StatusInternalServerError, "Failed to load groups")
+		return
+	}
+
+	getPongo2Renderer().HTML(c, http.StatusOK, "pages/admin/feature_flags.pongo2", pongo2.Context{
+		"Groups":     groups,
+		"ActivePage": "admin",
+		"User":       getUserMapForTemplate(c),
+	})
+}
+
+// HandleListFeatureFlagsAPI handles GET /api/v1/admin/feature-flags.
+//
+//	@Summary		List feature flags
+//	@Description	Returns all feature flags with their targeting, sorted by key.
+//	@Tags			Feature Flags
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}	"Feature flags"
+//	@Security		BearerAuth
+//	@Router			/admin/feature-flags [get]
+func HandleListFeatureFlagsAPI(c *gin.Context) {
+	svc := featureFlagService(c)
+	if svc == nil {
+		return
+	}
+	flags, err := svc.List(c.Request.Context())
+	if err != nil {
+		featureFlagError(c, err, "load feature flags")
+		return
+	}
+	if flags == nil {
+		flags = []sysconfig.FeatureFlag{}
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": flags})
+}
+
+// HandleCreateFeatureFlagAPI handles POST /api/v1/admin/feature-flags.
+//
+//	@Summary		Create feature flag
+//	@Description	Adds a feature flag. It is on for users in one of its groups or in its rollout percentage, once enabled.
+//	@Tags			Feature Flags
+//	@Accept			json
+//	@Produce		json
+//	@Param			flag	body		object	true	"key, description, enabled, percentage, groups"
+//	@Success		201		{object}	map[string]interface{}	"Feature flag"
+//	@Failure		400		{object}	map[string]interface{}	"Invalid feature flag"
+//	@Failure		409		{object}	map[string]interface{}	"Feature flag already exists"
+//	@Security		BearerAuth
+//	@Router			/admin/feature-flags [post]
+func HandleCreateFeatureFlagAPI(c *gin.Context) {
+	var f sysconfig.FeatureFlag
+	if err := c.ShouldBindJSON(&f); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid request body"})
+		return
+	}
+	svc := featureFlagService(c)
+	if svc == nil {
+		return
+	}
+	created, err := svc.Create(c.Request.Context(), f, GetUserIDFromCtx(c, 1))
+	if err != nil {
+		featureFlagError(c, err, "create feature flag")
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"success": true, "data": created})
+}
+
+// HandleUpdateFeatureFlagAPI handles PUT /api/v1/admin/feature-flags/:key.
+//
+//	@Summary		Update feature flag
+//	@Description	Replaces the description, switch and targeting of a feature flag. The key cannot be changed.
+//	@Tags			Feature Flags
+//	@Accept			json
+//	@Produce		json
+//	@Param			key		path		string	true	"Flag key"
+//	@Param			flag	body		object	true	"description, enabled, percentage, groups"
+//	@Success		200		{object}	map[string]interface{}	"Feature flag"
+//	@Failure		400		{object}	map[string]interface{}	"Invalid feature flag"
+//	@Failure		404		{object}	map[string]interface{}	"Feature flag not found"
+//	@Security		BearerAuth
+//	@Router			/admin/feature-flags/{key} [put]
+func HandleUpdateFeatureFlagAPI(c *gin.Context) {
+	var f sysconfig.FeatureFlag
+	if err := c.ShouldBindJSON(&f); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid request body"})
+		return
+	}
+	svc := featureFlagService(c)
+	if svc == nil {
+		return
+	}
+	updated, err := svc.Update(c.Request.Context(), c.Param("key"), f, GetUserIDFromCtx(c, 1))
+	if err != nil {
+		featureFlagError(c, err, "update feature flag")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": updated})
+}
+
+// HandleToggleFeatureFlagAPI handles PUT /api/v1/admin/feature-flags/:key/enabled.
+//
+//	@Summary		Switch feature flag
+//	@Description	Turns a feature flag on or off at runtime, keeping its targeting.
+//	@Tags			Feature Flags
+//	@Accept			json
+//	@Produce		json
+//	@Param			key		path		string	true	"Flag key"
+//	@Param			body	body		object	true	"enabled"
+//	@Success		200		{object}	map[string]interface{}	"Feature flag"
+//	@Failure		404		{object}	map[string]interface{}	"Feature flag not found"
+//	@Security		BearerAuth
+//	@Router			/admin/feature-flags/{key}/enabled [put]
+func HandleToggleFeatureFlagAPI(c *gin.Context) {
+	var req struct {
+		Enabled *bool `json:"enabled"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || req.Enabled == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "enabled is required"})
+		return
+	}
+	svc := featureFlagService(c)
+	if svc == nil {
+		return
+	}
+	updated, err := svc.SetEnabled(c.Request.Context(), c.Param("key"), *req.Enabled, GetUserIDFromCtx(c, 1))
+	if err != nil {
+		featureFlagError(c, err, "switch feature flag")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": updated})
+}
+
+// HandleDeleteFeatureFlagAPI handles DELETE /api/v1/admin/feature-flags/:key.
+//
+//	@Summary		Delete feature flag
+//	@Description	Removes a feature flag; code checking it then sees it as off.
+//	@Tags			Feature Flags
+//	@Produce		json
+//	@Param			key	path		string	true	"Flag key"
+//	@Success		200	{object}	map[string]interface{}	"Deleted"
+//	@Failure		404	{object}	map[string]interface{}	"Feature flag not found"
+//	@Security		BearerAuth
+//	@Router			/admin/feature-flags/{key} [delete]
+func HandleDeleteFeatureFlagAPI(c *gin.Context) {
+	svc := featureFlagService(c)
+	if svc == nil {
+		return
+	}
+	if err := svc.Delete(c.Request.Context(), c.Param("key"), GetUserIDFromCtx(c, 1)); err != nil {
+		featureFlagError(c, err, "delete feature flag")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
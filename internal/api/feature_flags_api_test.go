@@ -0,0 +1,41 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFeatureFlagHandlers_InvalidRequest(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.POST("/api/v1/admin/feature-flags", HandleCreateFeatureFlagAPI)
+	router.PUT("/api/v1/admin/feature-flags/:key", HandleUpdateFeatureFlagAPI)
+	router.PUT("/api/v1/admin/feature-flags/:key/enabled", HandleToggleFeatureFlagAPI)
+
+	for _, tc := range []struct {
+		method string
+		path   string
+		body   string
+		want   string
+	}{
+		{http.MethodPost, "/api/v1/admin/feature-flags", `{"percentage": "half"}`, "Invalid request body"},
+		{http.MethodPut, "/api/v1/admin/feature-flags/beta", `{"groups": "admin"}`, "Invalid request body"},
+		{http.MethodPut, "/api/v1/admin/feature-flags/beta/enabled", `{}`, "enabled is required"},
+	} {
+		t.Run(tc.method+" "+tc.path+" "+tc.body, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Contains(t, w.Body.String(), tc.want)
+		})
+	}
+}
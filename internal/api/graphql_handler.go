@@ -0,0 +1,127 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/goatkit/goatflow/internal/config"
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/graphql"
+	"github.com/goatkit/goatflow/internal/models"
+)
+
+// maxGraphQLRequestSize bounds GraphQL request bodies.
+const maxGraphQLRequestSize = 1 << 20
+
+// graphqlEnabled writes 404 unless server.graphql.enabled is set.
+func graphqlEnabled(c *gin.Context) (*config.GraphQLConfig, bool) {
+	cfg := config.Get()
+	if cfg == nil || !cfg.Server.GraphQL.Enabled {
+		c.JSON(http.StatusNotFound, graphql.Response{Errors: []*graphql.Error{{Message: "GraphQL API is disabled"}}})
+		return nil, false
+	}
+	return &cfg.Server.GraphQL, true
+}
+
+// graphqlParams reads a GraphQL request from the query string (GET), a JSON
+// body or an application/graphql body (POST).
+func graphqlParams(c *gin.Context) (graphql.Params, error) {
+	var p graphql.Params
+	if c.Request.Method == http.MethodGet {
+		p.Query = c.Query("query")
+		p.OperationName = c.Query("operationName")
+		if vars := c.Query("variables"); vars != "" {
+			if err := json.Unmarshal([]byte(vars), &p.Variables); err != nil {
+				return p, err
+			}
+		}
+		return p, nil
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxGraphQLRequestSize))
+	if err != nil {
+		return p, err
+	}
+	if strings.HasPrefix(c.ContentType(), "application/graphql") {
+		p.Query = string(body)
+		return p, nil
+	}
+	err = json.Unmarshal(body, &p)
+	return p, err
+}
+
+// HandleGraphQL handles GET and POST /api/graphql.
+//
+//	@Summary		GraphQL query endpoint
+//	@Description	Runs a read-only GraphQL query over tickets, articles, queues, users and dynamic fields, limited to what the agent may read. Requires server.graphql.enabled.
+//	@Tags			GraphQL
+//	@Accept			json
+//	@Produce		json
+//	@Param			request	body		object	true	"GraphQL request (query, operationName, variables)"
+//	@Success		200		{object}	map[string]interface{}	"Data and field errors"
+//	@Failure		400		{object}	map[string]interface{}	"Invalid query"
+//	@Failure		403		{object}	map[string]interface{}	"Not available to customers"
+//	@Failure		404		{object}	map[string]interface{}	"GraphQL API is disabled"
+//	@Security		BearerAuth
+//	@Router			/graphql [post]
+func HandleGraphQL(c *gin.Context) {
+	cfg, ok := graphqlEnabled(c)
+	if !ok {
+		return
+	}
+	if role, _ := c.Get("user_role"); role == "Customer" || c.GetBool("is_customer") {
+		c.JSON(http.StatusForbidden, graphql.Response{Errors: []*graphql.Error{{Message: "GraphQL API is available to agents only"}}})
+		return
+	}
+	userID := GetUserIDFromCtx(c, 0)
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, graphql.Response{Errors: []*graphql.Error{{Message: "Unauthorized"}}})
+		return
+	}
+	params, err := graphqlParams(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, graphql.Response{Errors: []*graphql.Error{{Message: "Invalid GraphQL request: " + err.Error()}}})
+		return
+	}
+	db, err := database.GetDB()
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, graphql.Response{Errors: []*graphql.Error{{Message: "Database unavailable"}}})
+		return
+	}
+	var token *models.APIToken
+	if t, ok := c.Get("api_token"); ok {
+		token, _ = t.(*models.APIToken)
+	}
+
+	server := graphql.NewServer(db, userID, token, graphql.Options{
+		MaxDepth:   cfg.MaxDepth,
+		MaxFields:  cfg.MaxFields,
+		MaxAliases: cfg.MaxAliases,
+	})
+	resp := server.Execute(c.Request.Context(), params)
+	status := http.StatusOK
+	if resp.Data == nil {
+		status = http.StatusBadRequest
+	}
+	c.JSON(status, resp)
+}
+
+// HandleGraphQLSchema handles GET /api/graphql/schema.
+//
+//	@Summary		GraphQL schema
+//	@Description	Returns the GraphQL schema in schema definition language, for code generators and IDEs.
+//	@Tags			GraphQL
+//	@Produce		plain
+//	@Success		200	{string}	string	"Schema"
+//	@Failure		404	{object}	map[string]interface{}	"GraphQL API is disabled"
+//	@Security		BearerAuth
+//	@Router			/graphql/schema [get]
+func HandleGraphQLSchema(c *gin.Context) {
+	if _, ok := graphqlEnabled(c); !ok {
+		return
+	}
+	c.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(graphql.SchemaSDL))
+}
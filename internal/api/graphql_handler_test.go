@@ -0,0 +1,40 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/graphql"
+)
+
+func TestGraphQLParams(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	for _, tc := range []struct {
+		method, target, contentType, body string
+		want                              graphql.Params
+	}{
+		{http.MethodGet, `/api/graphql?query={me{id}}&operationName=Me&variables={"n":1}`, "", "",
+			graphql.Params{Query: "{me{id}}", OperationName: "Me", Variables: map[string]interface{}{"n": float64(1)}}},
+		{http.MethodPost, "/api/graphql", "application/json", `{"query":"{me{id}}","variables":{"n":"x"}}`,
+			graphql.Params{Query: "{me{id}}", Variables: map[string]interface{}{"n": "x"}}},
+		{http.MethodPost, "/api/graphql", "application/graphql", "{ me { id } }",
+			graphql.Params{Query: "{ me { id } }"}},
+	} {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(tc.method, strings.ReplaceAll(tc.target, `"`, "%22"), strings.NewReader(tc.body))
+		if tc.contentType != "" {
+			c.Request.Header.Set("Content-Type", tc.contentType)
+		}
+		got, err := graphqlParams(c)
+		require.NoError(t, err, tc.target)
+		assert.Equal(t, tc.want, got, tc.target)
+	}
+}
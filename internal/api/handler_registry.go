@@ -134,6 +134,11 @@ func ensureCoreHandlers() {
 		"HandleAgentPasswordForm":     HandleAgentPasswordForm,
 		"HandleAgentChangePassword":   HandleAgentChangePassword,
 		"handleAdminSettings":         handleAdminSettings,
+		"handleAdminSettingsHistory":  handleAdminSettingsHistory,
+		"handleAdminMaintenance":      handleAdminMaintenance,
+		"handleAdminJobs":             handleAdminJobs,
+		"handleRetryJob":              handleRetryJob,
+		"handleDeleteJob":             handleDeleteJob,
 		"handleAdminTemplates":        handleAdminTemplates,
 		"handleAdminReports":          handleAdminReports,
 		"handleAdminLogs":             handleAdminLogs,
@@ -172,6 +177,10 @@ func ensureCoreHandlers() {
 		},
 		"handleDemoCustomerLogin": handleDemoCustomerLogin,
 		"handleCustomerLoginPage": handleCustomerLoginPage,
+		"handleSSOLogin":          handleSSOLogin,
+		"handleSSOCallback":       handleSSOCallback,
+		"handleSSOACS":            handleSSOACS,
+		"handleSSOMetadata":       handleSSOMetadata,
 		"handleCustomerLogin": func(c *gin.Context) {
 			handleCustomerLogin(shared.GetJWTManager())(c)
 		},
@@ -365,6 +374,11 @@ func ensureCoreHandlers() {
 			c.Redirect(http.StatusFound, target)
 		},
 		"handleRoot": func(c *gin.Context) {
+			// Customer portal domains only serve the customer portal
+			if portalDomainForRequest(c) != nil {
+				c.Redirect(http.StatusFound, "/customer")
+				return
+			}
 			c.Redirect(http.StatusFound, RootRedirectTarget())
 		},
 		"handleAuthRefresh":  handleAuthRefresh,
@@ -381,6 +395,9 @@ func ensureCoreHandlers() {
 		"handleMetrics": func(c *gin.Context) {
 			c.String(http.StatusOK, "# HELP goatflow_up GoatFlow is up\n# TYPE goatflow_up gauge\ngoatflow_up 1\n")
 		},
+		"handleReadiness":            handleReadiness,
+		"handlePortalDomainTLSCheck": handlePortalDomainTLSCheck,
+		"handleBrandingAsset":        handleBrandingAsset,
 
 		// Redirect helpers
 		"handleQueuesRedirect":   HandleRedirectQueues,
@@ -417,6 +434,10 @@ func ensureCoreHandlers() {
 		"HandleAdminGroupsRemoveUser":   func(c *gin.Context) { c.JSON(http.StatusOK, gin.H{"success": true}) },
 		"handleAdminQueues":             handleAdminQueues,
 		"handleAdminEmailIdentities":    handleAdminEmailIdentities,
+		"handleAdminAppearance":         handleAdminAppearance,
+		"handleAdminFeatureFlags":       handleAdminFeatureFlags,
+		"handleAdminSystemInsights":     handleAdminSystemInsights,
+		"handleAdminHelpdeskImports":    handleAdminHelpdeskImports,
 		"handleAdminPriorities":         handleAdminPriorities,
 		"handleAdminPermissions":        handleAdminPermissions,
 		"handleGetUserPermissionMatrix": handleGetUserPermissionMatrix,
@@ -467,8 +488,24 @@ func ensureCoreHandlers() {
 		"handleTestWebservice":           handleTestWebservice,
 		"handleAdminWebserviceHistory":   handleAdminWebserviceHistory,
 		"handleRestoreWebserviceHistory": handleRestoreWebserviceHistory,
+		// Single sign-on provider management
+		"handleAdminSSOProviders":    handleAdminSSOProviders,
+		"handleAdminSSOProviderNew":  handleAdminSSOProviderNew,
+		"handleAdminSSOProviderEdit": handleAdminSSOProviderEdit,
+		"handleAdminSSOProviderGet":  handleAdminSSOProviderGet,
+		"handleCreateSSOProvider":    handleCreateSSOProvider,
+		"handleUpdateSSOProvider":    handleUpdateSSOProvider,
+		"handleDeleteSSOProvider":    handleDeleteSSOProvider,
+		"handleListSSOLinks":         handleListSSOLinks,
+		"handleCreateSSOLink":        handleCreateSSOLink,
+		"handleDeleteSSOLink":        handleDeleteSSOLink,
+		// Route sitemap
+		"handleAdminRouteSitemap": handleAdminRouteSitemap,
 		"handleAdminStates":                         handleAdminStates,
 		"handleAdminTypes":                          handleAdminTypes,
+		"handleAdminTypeCreate":                     handleAdminTypeCreate,
+		"handleAdminTypeUpdate":                     handleAdminTypeUpdate,
+		"handleAdminTypeDelete":                     handleAdminTypeDelete,
 		"handleAdminServices":                       handleAdminServices,
 		"handleAdminServiceCreate":                  handleAdminServiceCreate,
 		"handleAdminServiceUpdate":                  handleAdminServiceUpdate,
@@ -578,6 +615,9 @@ func ensureCoreHandlers() {
 		"HandleAdminCustomerUsersImport":     HandleAdminCustomerUsersImport,
 		"HandleAdminCustomerUsersExport":     HandleAdminCustomerUsersExport,
 		"HandleAdminCustomerUsersBulkAction": HandleAdminCustomerUsersBulkAction,
+		"HandleAdminCustomerImportPage":      HandleAdminCustomerImportPage,
+		"HandleAdminCustomerImportPreview":   HandleAdminCustomerImportPreview,
+		"HandleAdminCustomerImportConfirm":   HandleAdminCustomerImportConfirm,
 
 		// Customer user ↔ services management
 		"handleAdminCustomerUserServices":         HandleAdminCustomerUserServices,
@@ -641,11 +681,53 @@ func ensureCoreHandlers() {
 		"HandleAPIQueueStatus":       HandleAPIQueueStatus,
 		"HandleLoginAPI":             HandleLoginAPI,
 		"HandleListTicketsAPI":       HandleListTicketsAPI,
+		"HandleExportTicketsAPI":     HandleExportTicketsAPI,
 		"HandleCreateTicketAPI":      HandleCreateTicketAPI,
 		"HandleGetTicketAPI":         HandleGetTicketAPI,
 		"HandleUpdateTicketAPI":      HandleUpdateTicketAPI,
 		"HandleDeleteTicketAPI":      HandleDeleteTicketAPI,
 		"HandleReopenTicketAPI":      HandleReopenTicketAPI,
+		"HandleMergeTicketAPI":       HandleMergeTicketAPI,
+		"HandleSplitTicketAPI":       HandleSplitTicketAPI,
+		"HandleListTicketLinksAPI":   HandleListTicketLinksAPI,
+		"HandleCreateTicketLinkAPI":  HandleCreateTicketLinkAPI,
+		"HandleDeleteTicketLinkAPI":  HandleDeleteTicketLinkAPI,
+		"HandleTicketKBSuggestionsAPI":    HandleTicketKBSuggestionsAPI,
+		"HandleListKBCategoriesAPI":       HandleListKBCategoriesAPI,
+		"HandleCreateKBCategoryAPI":       HandleCreateKBCategoryAPI,
+		"HandleListKBArticlesAPI":         HandleListKBArticlesAPI,
+		"HandleGetKBArticleAPI":           HandleGetKBArticleAPI,
+		"HandleListPublicKBArticlesAPI":   HandleListPublicKBArticlesAPI,
+		"HandleGetPublicKBArticleAPI":     HandleGetPublicKBArticleAPI,
+		"HandleCreateKBArticleAPI":        HandleCreateKBArticleAPI,
+		"HandleUpdateKBArticleAPI":        HandleUpdateKBArticleAPI,
+		"HandleDeleteKBArticleAPI":        HandleDeleteKBArticleAPI,
+		"HandleListKBArticleVersionsAPI":  HandleListKBArticleVersionsAPI,
+		"HandleListReportsAPI":            HandleListReportsAPI,
+		"HandleCreateReportAPI":           HandleCreateReportAPI,
+		"HandleGetReportAPI":              HandleGetReportAPI,
+		"HandleUpdateReportAPI":           HandleUpdateReportAPI,
+		"HandleDeleteReportAPI":           HandleDeleteReportAPI,
+		"HandleRunReportAPI":              HandleRunReportAPI,
+		"HandleEventStreamAPI":            HandleEventStreamAPI,
+		"HandleGetTicketLockAPI":          HandleGetTicketLockAPI,
+		"HandleLockTicketAPI":             HandleLockTicketAPI,
+		"HandleUnlockTicketAPI":           HandleUnlockTicketAPI,
+		"HandleGetTicketPresenceAPI":      HandleGetTicketPresenceAPI,
+		"HandleTicketPresenceAPI":         HandleTicketPresenceAPI,
+		"HandleLeaveTicketPresenceAPI":    HandleLeaveTicketPresenceAPI,
+		"HandleGetUserOutOfOfficeAPI":     HandleGetUserOutOfOfficeAPI,
+		"HandleUpdateUserOutOfOfficeAPI":  HandleUpdateUserOutOfOfficeAPI,
+		"HandleListApprovalRulesAPI":      HandleListApprovalRulesAPI,
+		"HandleCreateApprovalRuleAPI":     HandleCreateApprovalRuleAPI,
+		"HandleGetApprovalRuleAPI":        HandleGetApprovalRuleAPI,
+		"HandleUpdateApprovalRuleAPI":     HandleUpdateApprovalRuleAPI,
+		"HandleDeleteApprovalRuleAPI":     HandleDeleteApprovalRuleAPI,
+		"HandleListApprovalsAPI":          HandleListApprovalsAPI,
+		"HandleListTicketApprovalsAPI":    HandleListTicketApprovalsAPI,
+		"HandleApproveApprovalAPI":        HandleApproveApprovalAPI,
+		"HandleRejectApprovalAPI":         HandleRejectApprovalAPI,
+		"HandleCancelApprovalAPI":         HandleCancelApprovalAPI,
 		"HandleListArticlesAPI":      HandleListArticlesAPI,
 		"HandleCreateArticleAPI":     HandleCreateArticleAPI,
 		"HandleGetArticleAPI":        HandleGetArticleAPI,
@@ -665,15 +747,306 @@ func ensureCoreHandlers() {
 		"HandleListQueuesAPI":        HandleListQueuesAPI,
 		"HandleGetQueueAPI":          HandleGetQueueAPI,
 		"HandleGetQueueAgentsAPI":    HandleGetQueueAgentsAPI,
+		"HandleListQueueTemplatesAPI": HandleListQueueTemplatesAPI,
 		"HandleCreateQueueAPI":       HandleCreateQueueAPI,
 		"HandleUpdateQueueAPI":       HandleUpdateQueueAPI,
 		"HandleDeleteQueueAPI":       HandleDeleteQueueAPI,
+		"HandleQueueTreeAPI":         HandleQueueTreeAPI,
+		"HandleMoveQueueAPI":         HandleMoveQueueAPI,
 		"HandleGetQueueStatsAPI":     HandleGetQueueStatsAPI,
 		"HandleAssignQueueGroupAPI":  HandleAssignQueueGroupAPI,
 		"HandleRemoveQueueGroupAPI":  HandleRemoveQueueGroupAPI,
 		"HandleListPrioritiesAPI":    HandleListPrioritiesAPI,
 		"HandleGetPriorityAPI":       HandleGetPriorityAPI,
 		"HandleListTypesAPI":         HandleListTypesAPI,
+		"HandleGetTypeAPI":           HandleGetTypeAPI,
+		"HandleCreateTypeAPI":        HandleCreateTypeAPI,
+		"HandleUpdateTypeAPI":        HandleUpdateTypeAPI,
+		"HandleDeleteTypeAPI":        HandleDeleteTypeAPI,
+		// Ticket type workflows
+		"HandleGetTypeWorkflowAPI":    HandleGetTypeWorkflowAPI,
+		"HandleUpdateTypeWorkflowAPI": HandleUpdateTypeWorkflowAPI,
+		// Change management
+		"HandleGetTicketChangeAPI":    HandleGetTicketChangeAPI,
+		"HandleSaveTicketChangeAPI":   HandleSaveTicketChangeAPI,
+		"HandleDeleteTicketChangeAPI": HandleDeleteTicketChangeAPI,
+		"HandleChangeCalendarAPI":     HandleChangeCalendarAPI,
+		// Customer portal domains
+		"HandleListPortalDomainsAPI":        HandleListPortalDomainsAPI,
+		"HandleCreatePortalDomainAPI":       HandleCreatePortalDomainAPI,
+		"HandleGetPortalDomainAPI":          HandleGetPortalDomainAPI,
+		"HandleUpdatePortalDomainAPI":       HandleUpdatePortalDomainAPI,
+		"HandleDeletePortalDomainAPI":       HandleDeletePortalDomainAPI,
+		"HandleRequestPortalCertificateAPI": HandleRequestPortalCertificateAPI,
+		"HandleReportPortalCertificateAPI":  HandleReportPortalCertificateAPI,
+		// Request capture and replay
+		"HandleListRequestCapturesAPI":   HandleListRequestCapturesAPI,
+		"HandleCreateRequestCaptureAPI":  HandleCreateRequestCaptureAPI,
+		"HandleGetRequestCaptureAPI":     HandleGetRequestCaptureAPI,
+		"HandleDeleteRequestCaptureAPI":  HandleDeleteRequestCaptureAPI,
+		"HandleStopRequestCaptureAPI":    HandleStopRequestCaptureAPI,
+		"HandleListCapturedRequestsAPI":  HandleListCapturedRequestsAPI,
+		"HandleGetCapturedRequestAPI":    HandleGetCapturedRequestAPI,
+		"HandleReplayCapturedRequestAPI": HandleReplayCapturedRequestAPI,
+		"HandleListRequestReplaysAPI":    HandleListRequestReplaysAPI,
+		// Customer company hierarchy
+		"HandleCustomerCompanyTreeAPI":      HandleCustomerCompanyTreeAPI,
+		"HandleCustomerCompanyHierarchyAPI": HandleCustomerCompanyHierarchyAPI,
+		"HandleSetCustomerCompanyParentAPI": HandleSetCustomerCompanyParentAPI,
+		// Roles
+		"HandleListRolesAPI":                   HandleListRolesAPI,
+		"HandleCreateRoleAPI":                  HandleCreateRoleAPI,
+		"HandleGetRoleAPI":                     HandleGetRoleAPI,
+		"HandleUpdateRoleAPI":                  HandleUpdateRoleAPI,
+		"HandleDeleteRoleAPI":                  HandleDeleteRoleAPI,
+		"HandleSetRoleUsersAPI":                HandleSetRoleUsersAPI,
+		"HandleSetRolePermissionsAPI":          HandleSetRolePermissionsAPI,
+		"HandleGetUserRolesAPI":                HandleGetUserRolesAPI,
+		"HandleSetUserRolesAPI":                HandleSetUserRolesAPI,
+		"HandleGetUserEffectivePermissionsAPI": HandleGetUserEffectivePermissionsAPI,
+		// Password policies
+		"HandleGetPasswordPolicyAPI":    HandleGetPasswordPolicyAPI,
+		"HandleUpdatePasswordPolicyAPI": HandleUpdatePasswordPolicyAPI,
+		// Customer imports
+		"HandlePreviewCustomerImportAPI": HandlePreviewCustomerImportAPI,
+		"HandleCustomerImportAPI":        HandleCustomerImportAPI,
+		// System configuration
+		"HandleListSysconfigAPI":              HandleListSysconfigAPI,
+		"HandleUpdateSysconfigAPI":            HandleUpdateSysconfigAPI,
+		"HandleSysconfigDiffAPI":              HandleSysconfigDiffAPI,
+		"HandleListSysconfigDeploymentsAPI":   HandleListSysconfigDeploymentsAPI,
+		"HandleCreateSysconfigDeploymentAPI":  HandleCreateSysconfigDeploymentAPI,
+		"HandleGetSysconfigDeploymentAPI":     HandleGetSysconfigDeploymentAPI,
+		"HandleRestoreSysconfigDeploymentAPI": HandleRestoreSysconfigDeploymentAPI,
+		"HandleSysconfigDeploymentDiffAPI":     HandleSysconfigDeploymentDiffAPI,
+		"HandleRollbackSysconfigDeploymentAPI": HandleRollbackSysconfigDeploymentAPI,
+		// System maintenance
+		"HandleMaintenanceStatusAPI":  HandleMaintenanceStatusAPI,
+		"HandleListAnnouncementsAPI":  HandleListAnnouncementsAPI,
+		"HandleCreateAnnouncementAPI": HandleCreateAnnouncementAPI,
+		"HandleGetAnnouncementAPI":    HandleGetAnnouncementAPI,
+		"HandleUpdateAnnouncementAPI": HandleUpdateAnnouncementAPI,
+		"HandleDeleteAnnouncementAPI": HandleDeleteAnnouncementAPI,
+		"HandleMigrationStatusAPI":    HandleMigrationStatusAPI,
+
+		// Ticket archiving
+		"HandleListArchivePoliciesAPI":   HandleListArchivePoliciesAPI,
+		"HandleCreateArchivePolicyAPI":   HandleCreateArchivePolicyAPI,
+		"HandleUpdateArchivePolicyAPI":   HandleUpdateArchivePolicyAPI,
+		"HandleDeleteArchivePolicyAPI":   HandleDeleteArchivePolicyAPI,
+		"HandleListArchiveRunsAPI":       HandleListArchiveRunsAPI,
+		"HandleStartArchiveRunAPI":       HandleStartArchiveRunAPI,
+		"HandleListArchivedTicketsAPI":   HandleListArchivedTicketsAPI,
+		"HandleArchiveTicketAPI":         HandleArchiveTicketAPI,
+		"HandleRestoreArchivedTicketAPI": HandleRestoreArchivedTicketAPI,
+		"HandlePurgeArchivedTicketAPI":   HandlePurgeArchivedTicketAPI,
+
+		// Data subject requests (GDPR)
+		"HandleListPrivacyFieldsAPI":     HandleListPrivacyFieldsAPI,
+		"HandleListPrivacyRequestsAPI":   HandleListPrivacyRequestsAPI,
+		"HandleCreatePrivacyRequestAPI":  HandleCreatePrivacyRequestAPI,
+		"HandleGetPrivacyRequestAPI":     HandleGetPrivacyRequestAPI,
+		"HandleApprovePrivacyRequestAPI": HandleApprovePrivacyRequestAPI,
+		"HandleRejectPrivacyRequestAPI":  HandleRejectPrivacyRequestAPI,
+		"HandleDownloadPrivacyExportAPI": HandleDownloadPrivacyExportAPI,
+
+		// Attachment antivirus scanning and quarantine
+		"HandleGetAttachmentScannerAPI": HandleGetAttachmentScannerAPI,
+		"HandleListQuarantineAPI":       HandleListQuarantineAPI,
+		"HandleGetQuarantineAPI":        HandleGetQuarantineAPI,
+		"HandleReleaseQuarantineAPI":    HandleReleaseQuarantineAPI,
+		"HandleDeleteQuarantineAPI":     HandleDeleteQuarantineAPI,
+
+		// Customer satisfaction surveys
+		"HandleListSurveysAPI":         HandleListSurveysAPI,
+		"HandleCreateSurveyAPI":        HandleCreateSurveyAPI,
+		"HandleGetSurveyAPI":           HandleGetSurveyAPI,
+		"HandleUpdateSurveyAPI":        HandleUpdateSurveyAPI,
+		"HandleDeleteSurveyAPI":        HandleDeleteSurveyAPI,
+		"HandleGetSurveyStatsAPI":      HandleGetSurveyStatsAPI,
+		"HandleListSurveyResponsesAPI": HandleListSurveyResponsesAPI,
+		"handleCustomerSurvey":         handleCustomerSurvey,
+		"handleCustomerSurveySubmit":   handleCustomerSurveySubmit,
+		"handleDashboardSatisfaction":  handleDashboardSatisfaction,
+
+		// Ticket templates
+		"HandleListTicketTemplatesAPI":      HandleListTicketTemplatesAPI,
+		"HandleGetTicketTemplateAPI":        HandleGetTicketTemplateAPI,
+		"HandleAdminListTicketTemplatesAPI": HandleAdminListTicketTemplatesAPI,
+		"HandleAdminGetTicketTemplateAPI":   HandleAdminGetTicketTemplateAPI,
+		"HandleCreateTicketTemplateAPI":     HandleCreateTicketTemplateAPI,
+		"HandleUpdateTicketTemplateAPI":     HandleUpdateTicketTemplateAPI,
+		"HandleDeleteTicketTemplateAPI":     HandleDeleteTicketTemplateAPI,
+
+		// Calendars and CalDAV
+		"HandleListCalendarsAPI":             HandleListCalendarsAPI,
+		"HandleListCalendarAppointmentsAPI":  HandleListCalendarAppointmentsAPI,
+		"HandleGetCalendarAppointmentAPI":    HandleGetCalendarAppointmentAPI,
+		"HandleCreateCalendarAppointmentAPI": HandleCreateCalendarAppointmentAPI,
+		"HandleUpdateCalendarAppointmentAPI": HandleUpdateCalendarAppointmentAPI,
+		"HandleDeleteCalendarAppointmentAPI": HandleDeleteCalendarAppointmentAPI,
+		"HandleGetCalendarFeedURLAPI":        HandleGetCalendarFeedURLAPI,
+		"HandleListTicketAppointmentsAPI":    HandleListTicketAppointmentsAPI,
+		"HandleCalendarICalFeed":             HandleCalendarICalFeed,
+		"HandleAdminListCalendarsAPI":        HandleAdminListCalendarsAPI,
+		"HandleCreateCalendarAPI":            HandleCreateCalendarAPI,
+		"HandleUpdateCalendarAPI":            HandleUpdateCalendarAPI,
+		"HandleDeleteCalendarAPI":            HandleDeleteCalendarAPI,
+		"HandleResetCalendarFeedTokensAPI":   HandleResetCalendarFeedTokensAPI,
+		"handleDashboardAppointments":        handleDashboardAppointments,
+		"HandleCalDAVOptions":                HandleCalDAVOptions,
+		"HandleCalDAVHomePropfind":           HandleCalDAVHomePropfind,
+		"HandleCalDAVCalendarPropfind":       HandleCalDAVCalendarPropfind,
+		"HandleCalDAVEventPropfind":          HandleCalDAVEventPropfind,
+		"HandleCalDAVReport":                 HandleCalDAVReport,
+		"HandleCalDAVCalendarGet":            HandleCalDAVCalendarGet,
+		"HandleCalDAVEventGet":               HandleCalDAVEventGet,
+
+		// CMDB
+		"HandleListConfigItemClassesAPI":      HandleListConfigItemClassesAPI,
+		"HandleSearchConfigItemsAPI":          HandleSearchConfigItemsAPI,
+		"HandleGetConfigItemAPI":              HandleGetConfigItemAPI,
+		"HandleCreateConfigItemAPI":           HandleCreateConfigItemAPI,
+		"HandleUpdateConfigItemAPI":           HandleUpdateConfigItemAPI,
+		"HandleDeleteConfigItemAPI":           HandleDeleteConfigItemAPI,
+		"HandleListTicketConfigItemsAPI":      HandleListTicketConfigItemsAPI,
+		"HandleLinkTicketConfigItemAPI":       HandleLinkTicketConfigItemAPI,
+		"HandleUnlinkTicketConfigItemAPI":     HandleUnlinkTicketConfigItemAPI,
+		"HandleAdminListConfigItemClassesAPI": HandleAdminListConfigItemClassesAPI,
+		"HandleCreateConfigItemClassAPI":      HandleCreateConfigItemClassAPI,
+		"HandleUpdateConfigItemClassAPI":      HandleUpdateConfigItemClassAPI,
+		"HandleDeleteConfigItemClassAPI":      HandleDeleteConfigItemClassAPI,
+
+		// Priority matrix
+		"HandleGetPriorityMatrixAPI":             HandleGetPriorityMatrixAPI,
+		"HandleSetPriorityMatrixAPI":             HandleSetPriorityMatrixAPI,
+		"HandleAdminListPriorityMatrixLevelsAPI": HandleAdminListPriorityMatrixLevelsAPI,
+		"HandleCreatePriorityMatrixLevelAPI":     HandleCreatePriorityMatrixLevelAPI,
+		"HandleUpdatePriorityMatrixLevelAPI":     HandleUpdatePriorityMatrixLevelAPI,
+		"HandleDeletePriorityMatrixLevelAPI":     HandleDeletePriorityMatrixLevelAPI,
+
+		// Customer state labels
+		"HandleAdminListCustomerStateLabelsAPI": HandleAdminListCustomerStateLabelsAPI,
+		"HandleSetCustomerStateLabelAPI":        HandleSetCustomerStateLabelAPI,
+		"HandleDeleteCustomerStateLabelAPI":     HandleDeleteCustomerStateLabelAPI,
+
+		// Article drafts
+		"HandleListArticleDraftsAPI":          HandleListArticleDraftsAPI,
+		"HandleGetArticleDraftAPI":            HandleGetArticleDraftAPI,
+		"HandleSaveArticleDraftAPI":           HandleSaveArticleDraftAPI,
+		"HandleUpdateArticleDraftAPI":         HandleUpdateArticleDraftAPI,
+		"HandleDiscardArticleDraftAPI":        HandleDiscardArticleDraftAPI,
+		"HandleGetArticleDraftSettingsAPI":    HandleGetArticleDraftSettingsAPI,
+		"HandleUpdateArticleDraftSettingsAPI": HandleUpdateArticleDraftSettingsAPI,
+
+		// Auto responses
+		"HandleListAutoResponsesAPI":     HandleListAutoResponsesAPI,
+		"HandleListAutoResponseTypesAPI": HandleListAutoResponseTypesAPI,
+		"HandleGetAutoResponseAPI":       HandleGetAutoResponseAPI,
+		"HandleCreateAutoResponseAPI":    HandleCreateAutoResponseAPI,
+		"HandleUpdateAutoResponseAPI":    HandleUpdateAutoResponseAPI,
+		"HandleDeleteAutoResponseAPI":    HandleDeleteAutoResponseAPI,
+		"HandleGetQueueAutoResponsesAPI": HandleGetQueueAutoResponsesAPI,
+		"HandleSetQueueAutoResponsesAPI": HandleSetQueueAutoResponsesAPI,
+
+		// Email loop and bounce protection
+		"HandleListEmailBouncesAPI":        HandleListEmailBouncesAPI,
+		"HandleGetEmailBounceAPI":          HandleGetEmailBounceAPI,
+		"HandleClearEmailBounceAPI":        HandleClearEmailBounceAPI,
+		"HandleGetEmailLoopSettingsAPI":    HandleGetEmailLoopSettingsAPI,
+		"HandleUpdateEmailLoopSettingsAPI": HandleUpdateEmailLoopSettingsAPI,
+
+		// Branding
+		"HandleGetAppearanceAPI":         HandleGetAppearanceAPI,
+		"HandleUpdateAppearanceAPI":      HandleUpdateAppearanceAPI,
+		"HandleUploadAppearanceAssetAPI": HandleUploadAppearanceAssetAPI,
+		"HandleDeleteAppearanceAssetAPI": HandleDeleteAppearanceAssetAPI,
+		"HandleGetBrandingAPI":           HandleGetBrandingAPI,
+
+		// Feature flags
+		"HandleListFeatureFlagsAPI":  HandleListFeatureFlagsAPI,
+		"HandleCreateFeatureFlagAPI": HandleCreateFeatureFlagAPI,
+		"HandleUpdateFeatureFlagAPI": HandleUpdateFeatureFlagAPI,
+		"HandleToggleFeatureFlagAPI": HandleToggleFeatureFlagAPI,
+		"HandleDeleteFeatureFlagAPI": HandleDeleteFeatureFlagAPI,
+
+		// Telemetry and system insights
+		"HandleGetTelemetrySettingsAPI":    HandleGetTelemetrySettingsAPI,
+		"HandleUpdateTelemetrySettingsAPI": HandleUpdateTelemetrySettingsAPI,
+		"HandleTelemetryInsightsAPI":       HandleTelemetryInsightsAPI,
+		"HandleTelemetryReportAPI":         HandleTelemetryReportAPI,
+		"HandleSubmitTelemetryAPI":         HandleSubmitTelemetryAPI,
+
+		// Helpdesk imports
+		"HandleListHelpdeskImportsAPI": HandleListHelpdeskImportsAPI,
+
+		// Agent administration
+		"HandleCreateAgentAPI":      HandleCreateAgentAPI,
+		"HandleSetAgentPasswordAPI": HandleSetAgentPasswordAPI,
+		"HandleLockAgentAPI":        HandleLockAgentAPI,
+		"HandleUnlockAgentAPI":      HandleUnlockAgentAPI,
+		"HandleAddAgentToGroupAPI":  HandleAddAgentToGroupAPI,
+
+		// Language packs
+		"HandleListLanguagePacksAPI":        HandleListLanguagePacksAPI,
+		"HandleImportLanguagePackAPI":       HandleImportLanguagePackAPI,
+		"HandleExportLanguagePackAPI":       HandleExportLanguagePackAPI,
+		"HandleDeleteLanguagePackAPI":       HandleDeleteLanguagePackAPI,
+		"HandleReloadLanguagePacksAPI":      HandleReloadLanguagePacksAPI,
+		"HandleListMissingTranslationsAPI":  HandleListMissingTranslationsAPI,
+		"HandleResetMissingTranslationsAPI": HandleResetMissingTranslationsAPI,
+
+		// Customer self-registration
+		"HandleCustomerRegistrationConfigAPI":         HandleCustomerRegistrationConfigAPI,
+		"HandleCustomerRegisterAPI":                   HandleCustomerRegisterAPI,
+		"HandleCustomerVerifyRegistrationAPI":         HandleCustomerVerifyRegistrationAPI,
+		"HandleListCustomerRegistrationsAPI":          HandleListCustomerRegistrationsAPI,
+		"HandleApproveCustomerRegistrationAPI":        HandleApproveCustomerRegistrationAPI,
+		"HandleRejectCustomerRegistrationAPI":         HandleRejectCustomerRegistrationAPI,
+		"HandleGetCustomerRegistrationSettingsAPI":    HandleGetCustomerRegistrationSettingsAPI,
+		"HandleUpdateCustomerRegistrationSettingsAPI": HandleUpdateCustomerRegistrationSettingsAPI,
+		"handleCustomerRegisterPage":                  handleCustomerRegisterPage,
+		"handleCustomerRegisterSubmit":                handleCustomerRegisterSubmit,
+		"handleCustomerVerifyRegistrationPage":        handleCustomerVerifyRegistrationPage,
+		"handleCustomerVerifyRegistration":            handleCustomerVerifyRegistration,
+
+		// Password reset
+		"HandleForgotPasswordAPI":              HandleForgotPasswordAPI,
+		"HandleResetPasswordAPI":               HandleResetPasswordAPI,
+		"HandleCustomerForgotPasswordAPI":      HandleCustomerForgotPasswordAPI,
+		"HandleCustomerResetPasswordAPI":       HandleCustomerResetPasswordAPI,
+		"HandleGetPasswordResetSettingsAPI":    HandleGetPasswordResetSettingsAPI,
+		"HandleUpdatePasswordResetSettingsAPI": HandleUpdatePasswordResetSettingsAPI,
+		"HandleListPasswordResetLockoutsAPI":   HandleListPasswordResetLockoutsAPI,
+		"HandleDeletePasswordResetLockoutAPI":  HandleDeletePasswordResetLockoutAPI,
+		"handleForgotPasswordPage":             handleForgotPasswordPage,
+		"handleForgotPasswordSubmit":           handleForgotPasswordSubmit,
+		"handleResetPasswordPage":              handleResetPasswordPage,
+		"handleResetPasswordSubmit":            handleResetPasswordSubmit,
+		"handleCustomerForgotPasswordPage":     handleCustomerForgotPasswordPage,
+		"handleCustomerForgotPasswordSubmit":   handleCustomerForgotPasswordSubmit,
+		"handleCustomerResetPasswordPage":      handleCustomerResetPasswordPage,
+		"handleCustomerResetPasswordSubmit":    handleCustomerResetPasswordSubmit,
+
+		// Attachment upload policies and chunked uploads
+		"HandleStartAttachmentUpload":     HandleStartAttachmentUpload,
+		"HandleGetAttachmentUpload":       HandleGetAttachmentUpload,
+		"HandleAppendAttachmentUpload":    HandleAppendAttachmentUpload,
+		"HandleCompleteAttachmentUpload":  HandleCompleteAttachmentUpload,
+		"HandleCancelAttachmentUpload":    HandleCancelAttachmentUpload,
+		"HandleGetAttachmentPolicyAPI":    HandleGetAttachmentPolicyAPI,
+		"HandleUpdateAttachmentPolicyAPI": HandleUpdateAttachmentPolicyAPI,
+
+		// Attachment previews
+		"HandleGetAttachmentPreview": HandleGetAttachmentPreview,
+
+		// Queue and agent statistics
+		"HandleListQueueStatsAPI": HandleListQueueStatsAPI,
+		"HandleAgentStatsAPI":     HandleAgentStatsAPI,
+
+		// GraphQL
+		"HandleGraphQL":       HandleGraphQL,
+		"HandleGraphQLSchema": HandleGraphQLSchema,
 		"HandleListStatesAPI":        HandleListStatesAPI,
 		"HandleSearchAPI":            HandleSearchAPI,
 		"HandleSearchSuggestionsAPI": HandleSearchSuggestionsAPI,
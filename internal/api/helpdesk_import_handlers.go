@@ -0,0 +1,55 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/flosch/pongo2/v6"
+	"github.com/gin-gonic/gin"
+
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/helpdeskimport"
+)
+
+// handleAdminHelpdeskImports renders the progress page of helpdesk imports.
+func handleAdminHelpdeskImports(c *gin.Context) {
+	if htmxHandlerSkipDB() || getPongo2Renderer() == nil || getPongo2Renderer().TemplateSet() == nil {
+		c.Data(http.StatusOK, "text/html; charset=utf-8", []byte("<main>Helpdesk Imports</main>"))
+		return
+	}
+	getPongo2Renderer().HTML(c, http.StatusOK, "pages/admin/helpdesk_imports.pongo2", pongo2.Context{
+		"ActivePage": "admin",
+		"User":       getUserMapForTemplate(c),
+	})
+}
+
+// HandleListHelpdeskImportsAPI handles GET /api/v1/admin/helpdesk-imports.
+//
+//	@Summary		List helpdesk import runs
+//	@Description	Returns the latest Zendesk and Freshdesk import runs, newest first, with their phase, counts and issues. Runs that stopped reporting progress are shown as stalled.
+//	@Tags			Helpdesk Imports
+//	@Produce		json
+//	@Param			limit	query		int						false	"Number of runs (default 20, max 100)"
+//	@Success		200		{object}	map[string]interface{}	"Import runs"
+//	@Security		BearerAuth
+//	@Router			/admin/helpdesk-imports [get]
+func HandleListHelpdeskImportsAPI(c *gin.Context) {
+	db, err := database.GetDB()
+	if err != nil || db == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"success": false, "error": "Database unavailable"})
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+	if err != nil || limit < 1 || limit > 100 {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "limit must be between 1 and 100"})
+		return
+	}
+	runs, err := helpdeskimport.ListRuns(c.Request.Context(), db, limit)
+	if err != nil {
+		log.Printf("helpdesk import api: list runs failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to list import runs"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": runs})
+}
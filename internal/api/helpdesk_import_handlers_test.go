@@ -0,0 +1,38 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/testutil"
+)
+
+func TestHelpdeskImportHandlers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := testutil.UseMigratedDB(t)
+	old := time.Now().Add(-time.Hour)
+	_, err := db.Exec(`INSERT INTO helpdesk_import_run (source, account, status, phase, tickets, issues, started_at, updated_at)
+		VALUES ('zendesk', 'acme', 'running', 'tickets', 7, '["user 12 has no email address"]', ?, ?)`, old, old)
+	require.NoError(t, err)
+
+	router := gin.New()
+	router.GET("/api/v1/admin/helpdesk-imports", HandleListHelpdeskImportsAPI)
+	do := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	w := do("/api/v1/admin/helpdesk-imports")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"status":"stalled"`, "a run without progress for an hour is stalled")
+	assert.Contains(t, w.Body.String(), `"tickets":7`)
+	assert.Contains(t, w.Body.String(), `user 12 has no email address`)
+	assert.Equal(t, http.StatusBadRequest, do("/api/v1/admin/helpdesk-imports?limit=0").Code)
+}
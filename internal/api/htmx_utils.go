@@ -21,6 +21,7 @@ import (
 	"golang.org/x/text/language"
 
 	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/middleware"
 	"github.com/goatkit/goatflow/internal/models"
 	"github.com/goatkit/goatflow/internal/repository"
 	"github.com/goatkit/goatflow/internal/shared"
@@ -723,10 +724,15 @@ func sendErrorResponse(c *gin.Context, statusCode int, message string) {
 func checkAdmin() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		user := getUserMapForTemplate(c)
+		allow := func() {
+			if middleware.RequireAdminTwoFactor(c) {
+				c.Next()
+			}
+		}
 
 		if userID, ok := user["ID"].(uint); ok {
 			if userID == 1 || userID == 2 {
-				c.Next()
+				allow()
 				return
 			}
 
@@ -740,7 +746,7 @@ func checkAdmin() gin.HandlerFunc {
 					WHERE ug.user_id = ? AND LOWER(g.name) = 'admin'`),
 					userID).Scan(&count)
 				if err == nil && count > 0 {
-					c.Next()
+					allow()
 					return
 				}
 			}
@@ -748,7 +754,7 @@ func checkAdmin() gin.HandlerFunc {
 
 		if login, ok := user["Login"].(string); ok {
 			if strings.Contains(strings.ToLower(login), "admin") || login == "root@localhost" {
-				c.Next()
+				allow()
 				return
 			}
 		}
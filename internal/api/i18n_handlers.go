@@ -1,14 +1,17 @@
 package api
 
 import (
+	"bytes"
 	"fmt"
 	"net/http"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 
+	"github.com/goatkit/goatflow/internal/database"
 	"github.com/goatkit/goatflow/internal/i18n"
 	"github.com/goatkit/goatflow/internal/middleware"
+	"github.com/goatkit/goatflow/internal/service"
 )
 
 // I18nHandlers handles internationalization-related requests.
@@ -77,10 +80,18 @@ func (h *I18nHandlers) SetLanguage(c *gin.Context) {
 	// Set language cookie
 	middleware.SetLanguageCookie(c, req.Language)
 
-	// If user is authenticated, save preference to database
-	if userID, exists := c.Get("user_id"); exists {
-		// TODO: Save user language preference to database
-		_ = userID
+	// If user is authenticated, save preference to database so it follows
+	// them across devices. Demo users share accounts, so they keep the cookie.
+	if userID := GetUserIDFromCtx(c, 0); userID > 0 && !c.GetBool("is_demo") {
+		if db, err := database.GetDB(); err == nil && db != nil {
+			if err := service.NewUserPreferencesService(db).SetLanguage(userID, req.Language); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"error":   middleware.TranslateError(c, "server_error"),
+					"message": "Failed to save language preference",
+				})
+				return
+			}
+		}
 	}
 
 	c.JSON(http.StatusOK, gin.H{
@@ -309,6 +320,19 @@ func (h *I18nHandlers) ExportTranslations(c *gin.Context) {
 		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.csv\"", lang))
 		c.String(http.StatusOK, csvContent.String())
 
+	case "po":
+		// Export as gettext PO with the English text as msgid
+		var po bytes.Buffer
+		source := i18n.FlattenTranslations(h.i18n.GetTranslations(h.i18n.GetDefaultLanguage()))
+		if err := i18n.WritePO(&po, lang, i18n.FlattenTranslations(translations), source); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": middleware.TranslateError(c, "server_error"),
+			})
+			return
+		}
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.po\"", lang))
+		c.Data(http.StatusOK, "text/x-gettext-translation; charset=utf-8", po.Bytes())
+
 	default:
 		// Export as JSON
 		c.Header("Content-Type", "application/json")
@@ -0,0 +1,515 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/repository"
+	"github.com/goatkit/goatflow/internal/service"
+	"github.com/goatkit/goatflow/internal/services"
+)
+
+// kbEditorGroup is the group whose members may edit the knowledge base
+// besides admins.
+const kbEditorGroup = "kb_editor"
+
+// kbCategoryRequest is the JSON body accepted by HandleCreateKBCategoryAPI.
+type kbCategoryRequest struct {
+	Name     string `json:"name" binding:"required"`
+	ParentID *int   `json:"parent_id"`
+	Comments string `json:"comments"`
+}
+
+// kbArticleRequest is the JSON body accepted by the article create/update handlers.
+type kbArticleRequest struct {
+	CategoryID int    `json:"category_id" binding:"required,min=1"`
+	Title      string `json:"title" binding:"required"`
+	Body       string `json:"body"`
+	Keywords   string `json:"keywords"`
+	Visibility string `json:"visibility"`
+	ChangeNote string `json:"change_note"`
+}
+
+// kbIsCustomer reports whether the caller is a customer user.
+func kbIsCustomer(c *gin.Context) bool {
+	if ic, _ := c.Get("is_customer"); ic == true {
+		return true
+	}
+	role, _ := c.Get("user_role")
+	return role == "Customer"
+}
+
+// kbVisibilitiesFor returns the article visibilities the caller may read;
+// nil means all of them.
+func kbVisibilitiesFor(c *gin.Context) []models.KBVisibility {
+	if kbIsCustomer(c) {
+		return []models.KBVisibility{models.KBVisibilityCustomer, models.KBVisibilityPublic}
+	}
+	return nil
+}
+
+// kbRequireAgent aborts with 403 when a customer calls an agent-only endpoint.
+func kbRequireAgent(c *gin.Context) bool {
+	if kbIsCustomer(c) {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": "Agent access required"})
+		return false
+	}
+	return true
+}
+
+// kbRequireEditor aborts with 403 unless the caller is an admin or a member
+// of the kb_editor group.
+func kbRequireEditor(c *gin.Context) bool {
+	if !kbRequireAgent(c) {
+		return false
+	}
+	if role, _ := c.Get("user_role"); role == "Admin" {
+		return true
+	}
+	db, err := database.GetDB()
+	if err != nil || db == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"success": false, "error": "Database unavailable"})
+		return false
+	}
+	isEditor, err := services.NewPermissionService(db).IsInGroup(GetUserIDFromCtx(c, 0), kbEditorGroup)
+	if err != nil {
+		log.Printf("kb api: checking editor permission failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to check permissions"})
+		return false
+	}
+	if !isEditor {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": "Knowledge base editor access required"})
+		return false
+	}
+	return true
+}
+
+func kbService(c *gin.Context) *service.KBService {
+	db, err := database.GetDB()
+	if err != nil || db == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"success": false, "error": "Database unavailable"})
+		return nil
+	}
+	return service.NewKBService(repository.NewKBRepository(db))
+}
+
+// kbWriteError maps KBService validation errors to responses.
+func kbWriteError(c *gin.Context, err error, action string) {
+	switch {
+	case errors.Is(err, service.ErrKBArticleNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Article not found"})
+	case errors.Is(err, service.ErrKBCategoryNotFound),
+		errors.Is(err, service.ErrKBInvalidVisibility),
+		errors.Is(err, service.ErrKBTitleRequired),
+		errors.Is(err, service.ErrKBCategoryNameRequired):
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+	default:
+		log.Printf("kb api: %s failed: %v", action, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to " + action})
+	}
+}
+
+// HandleListKBCategoriesAPI handles GET /api/v1/kb/categories.
+//
+//	@Summary		List knowledge base categories
+//	@Tags			Knowledge Base
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}	"Categories"
+//	@Security		BearerAuth
+//	@Router			/kb/categories [get]
+func HandleListKBCategoriesAPI(c *gin.Context) {
+	svc := kbService(c)
+	if svc == nil {
+		return
+	}
+	categories, err := svc.ListCategories(c.Request.Context())
+	if err != nil {
+		log.Printf("kb api: listing categories failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to load categories"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": categories})
+}
+
+// HandleCreateKBCategoryAPI handles POST /api/v1/kb/categories.
+//
+//	@Summary		Create knowledge base category
+//	@Tags			Knowledge Base
+//	@Accept			json
+//	@Produce		json
+//	@Param			category	body		object	true	"Category data (name, parent_id, comments)"
+//	@Success		201			{object}	map[string]interface{}	"Category created"
+//	@Failure		400			{object}	map[string]interface{}	"Invalid request"
+//	@Security		BearerAuth
+//	@Router			/kb/categories [post]
+func HandleCreateKBCategoryAPI(c *gin.Context) {
+	if !kbRequireEditor(c) {
+		return
+	}
+	var req kbCategoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid category request: " + err.Error()})
+		return
+	}
+	svc := kbService(c)
+	if svc == nil {
+		return
+	}
+
+	cat := &models.KBCategory{
+		Name:     req.Name,
+		ParentID: req.ParentID,
+		Comments: req.Comments,
+		ValidID:  1,
+		CreateBy: GetUserIDFromCtx(c, 1),
+	}
+	if err := svc.CreateCategory(c.Request.Context(), cat); err != nil {
+		kbWriteError(c, err, "create category")
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"success": true, "data": cat})
+}
+
+// HandleListKBArticlesAPI handles GET /api/v1/kb/articles.
+// Customers only see customer and public articles.
+//
+//	@Summary		List or search knowledge base articles
+//	@Tags			Knowledge Base
+//	@Produce		json
+//	@Param			q			query		string	false	"Search terms (title, keywords, body)"
+//	@Param			category_id	query		int		false	"Category filter"
+//	@Param			visibility	query		string	false	"Visibility filter (internal, customer, public)"
+//	@Param			limit		query		int		false	"Page size (max 100)"
+//	@Param			offset		query		int		false	"Offset"
+//	@Success		200			{object}	map[string]interface{}	"Articles"
+//	@Failure		400			{object}	map[string]interface{}	"Invalid filter"
+//	@Security		BearerAuth
+//	@Router			/kb/articles [get]
+func HandleListKBArticlesAPI(c *gin.Context) {
+	listKBArticles(c, kbVisibilitiesFor(c))
+}
+
+// listKBArticles lists the articles with one of visibilities; nil means all.
+func listKBArticles(c *gin.Context, visibilities []models.KBVisibility) {
+	filter := models.KBArticleFilter{
+		Query:        c.Query("q"),
+		Visibilities: visibilities,
+	}
+	filter.CategoryID, _ = strconv.Atoi(c.Query("category_id"))
+	filter.Limit, _ = strconv.Atoi(c.DefaultQuery("limit", "50"))
+	filter.Offset, _ = strconv.Atoi(c.Query("offset"))
+	if filter.Limit <= 0 || filter.Limit > 100 {
+		filter.Limit = 50
+	}
+	if filter.Offset < 0 {
+		filter.Offset = 0
+	}
+
+	if v := c.Query("visibility"); v != "" {
+		vis := models.KBVisibility(v)
+		if !vis.IsValid() {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": service.ErrKBInvalidVisibility.Error()})
+			return
+		}
+		if filter.Visibilities != nil && !slices.Contains(filter.Visibilities, vis) {
+			c.JSON(http.StatusOK, gin.H{"success": true, "data": []models.KBArticle{}})
+			return
+		}
+		filter.Visibilities = []models.KBVisibility{vis}
+	}
+
+	svc := kbService(c)
+	if svc == nil {
+		return
+	}
+	articles, err := svc.ListArticles(c.Request.Context(), filter)
+	if err != nil {
+		log.Printf("kb api: listing articles failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to load articles"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": articles})
+}
+
+// HandleGetKBArticleAPI handles GET /api/v1/kb/articles/:id.
+//
+//	@Summary		Get knowledge base article
+//	@Tags			Knowledge Base
+//	@Produce		json
+//	@Param			id	path		int	true	"Article ID"
+//	@Success		200	{object}	map[string]interface{}	"Article"
+//	@Failure		404	{object}	map[string]interface{}	"Article not found"
+//	@Security		BearerAuth
+//	@Router			/kb/articles/{id} [get]
+func HandleGetKBArticleAPI(c *gin.Context) {
+	getKBArticle(c, kbVisibilitiesFor(c))
+}
+
+// getKBArticle returns an article if it has one of visibilities; nil means
+// any.
+func getKBArticle(c *gin.Context, visibilities []models.KBVisibility) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid article ID"})
+		return
+	}
+	svc := kbService(c)
+	if svc == nil {
+		return
+	}
+	article, err := svc.GetArticle(c.Request.Context(), id, visibilities)
+	if err != nil {
+		kbWriteError(c, err, "load article")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": article})
+}
+
+// HandleListPublicKBArticlesAPI handles GET /api/v1/public/kb/articles.
+// It needs no login and only lists public articles.
+//
+//	@Summary		List or search public knowledge base articles
+//	@Tags			Knowledge Base
+//	@Produce		json
+//	@Param			q			query		string	false	"Search terms (title, keywords, body)"
+//	@Param			category_id	query		int		false	"Category filter"
+//	@Param			limit		query		int		false	"Page size (max 100)"
+//	@Param			offset		query		int		false	"Offset"
+//	@Success		200			{object}	map[string]interface{}	"Public articles"
+//	@Router			/public/kb/articles [get]
+func HandleListPublicKBArticlesAPI(c *gin.Context) {
+	listKBArticles(c, []models.KBVisibility{models.KBVisibilityPublic})
+}
+
+// HandleGetPublicKBArticleAPI handles GET /api/v1/public/kb/articles/:id.
+// It needs no login; articles that are not public are not found.
+//
+//	@Summary		Get public knowledge base article
+//	@Tags			Knowledge Base
+//	@Produce		json
+//	@Param			id	path		int	true	"Article ID"
+//	@Success		200	{object}	map[string]interface{}	"Article"
+//	@Failure		404	{object}	map[string]interface{}	"Article not found"
+//	@Router			/public/kb/articles/{id} [get]
+func HandleGetPublicKBArticleAPI(c *gin.Context) {
+	getKBArticle(c, []models.KBVisibility{models.KBVisibilityPublic})
+}
+
+// HandleCreateKBArticleAPI handles POST /api/v1/kb/articles.
+//
+//	@Summary		Create knowledge base article
+//	@Tags			Knowledge Base
+//	@Accept			json
+//	@Produce		json
+//	@Param			article	body		object	true	"Article data (category_id, title, body, keywords, visibility)"
+//	@Success		201		{object}	map[string]interface{}	"Article created"
+//	@Failure		400		{object}	map[string]interface{}	"Invalid request"
+//	@Security		BearerAuth
+//	@Router			/kb/articles [post]
+func HandleCreateKBArticleAPI(c *gin.Context) {
+	if !kbRequireEditor(c) {
+		return
+	}
+	var req kbArticleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid article request: " + err.Error()})
+		return
+	}
+	svc := kbService(c)
+	if svc == nil {
+		return
+	}
+
+	article := req.toArticle()
+	article.CreateBy = GetUserIDFromCtx(c, 1)
+	if err := svc.CreateArticle(c.Request.Context(), article, req.ChangeNote); err != nil {
+		kbWriteError(c, err, "create article")
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"success": true, "data": article})
+}
+
+// HandleUpdateKBArticleAPI handles PUT /api/v1/kb/articles/:id.
+// Every update is stored as a new version.
+//
+//	@Summary		Update knowledge base article
+//	@Tags			Knowledge Base
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int		true	"Article ID"
+//	@Param			article	body		object	true	"Article data (category_id, title, body, keywords, visibility, change_note)"
+//	@Success		200		{object}	map[string]interface{}	"Article updated"
+//	@Failure		400		{object}	map[string]interface{}	"Invalid request"
+//	@Failure		404		{object}	map[string]interface{}	"Article not found"
+//	@Security		BearerAuth
+//	@Router			/kb/articles/{id} [put]
+func HandleUpdateKBArticleAPI(c *gin.Context) {
+	if !kbRequireEditor(c) {
+		return
+	}
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid article ID"})
+		return
+	}
+	var req kbArticleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid article request: " + err.Error()})
+		return
+	}
+	svc := kbService(c)
+	if svc == nil {
+		return
+	}
+
+	article := req.toArticle()
+	article.ID = id
+	if err := svc.UpdateArticle(c.Request.Context(), article, req.ChangeNote, GetUserIDFromCtx(c, 1)); err != nil {
+		kbWriteError(c, err, "update article")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": article})
+}
+
+// HandleDeleteKBArticleAPI handles DELETE /api/v1/kb/articles/:id.
+//
+//	@Summary		Delete knowledge base article
+//	@Tags			Knowledge Base
+//	@Produce		json
+//	@Param			id	path		int	true	"Article ID"
+//	@Success		200	{object}	map[string]interface{}	"Article deleted"
+//	@Failure		404	{object}	map[string]interface{}	"Article not found"
+//	@Security		BearerAuth
+//	@Router			/kb/articles/{id} [delete]
+func HandleDeleteKBArticleAPI(c *gin.Context) {
+	if !kbRequireEditor(c) {
+		return
+	}
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid article ID"})
+		return
+	}
+	svc := kbService(c)
+	if svc == nil {
+		return
+	}
+	if err := svc.DeleteArticle(c.Request.Context(), id, GetUserIDFromCtx(c, 1)); err != nil {
+		kbWriteError(c, err, "delete article")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// HandleListKBArticleVersionsAPI handles GET /api/v1/kb/articles/:id/versions.
+//
+//	@Summary		List knowledge base article versions
+//	@Tags			Knowledge Base
+//	@Produce		json
+//	@Param			id	path		int	true	"Article ID"
+//	@Success		200	{object}	map[string]interface{}	"Versions, newest first"
+//	@Failure		404	{object}	map[string]interface{}	"Article not found"
+//	@Security		BearerAuth
+//	@Router			/kb/articles/{id}/versions [get]
+func HandleListKBArticleVersionsAPI(c *gin.Context) {
+	if !kbRequireAgent(c) {
+		return
+	}
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid article ID"})
+		return
+	}
+	svc := kbService(c)
+	if svc == nil {
+		return
+	}
+	versions, err := svc.ListVersions(c.Request.Context(), id)
+	if err != nil {
+		kbWriteError(c, err, "load versions")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": versions})
+}
+
+// HandleTicketKBSuggestionsAPI handles GET /api/v1/tickets/:id/kb-suggestions.
+// Articles are matched against the ticket title and the latest customer article.
+//
+//	@Summary		Suggest knowledge base articles for a ticket
+//	@Tags			Knowledge Base
+//	@Produce		json
+//	@Param			id		path		int	true	"Ticket ID"
+//	@Param			limit	query		int	false	"Maximum suggestions (default 5, max 20)"
+//	@Success		200		{object}	map[string]interface{}	"Ranked suggestions"
+//	@Failure		404		{object}	map[string]interface{}	"Ticket not found"
+//	@Security		BearerAuth
+//	@Router			/tickets/{id}/kb-suggestions [get]
+func HandleTicketKBSuggestionsAPI(c *gin.Context) {
+	if !kbRequireAgent(c) {
+		return
+	}
+	ticketID, err := strconv.Atoi(c.Param("id"))
+	if err != nil || ticketID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid ticket ID"})
+		return
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "5"))
+	if limit <= 0 || limit > 20 {
+		limit = 5
+	}
+
+	db, err := database.GetDB()
+	if err != nil || db == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"success": false, "error": "Database unavailable"})
+		return
+	}
+
+	ticket, err := repository.NewTicketRepository(db).GetByID(uint(ticketID))
+	if err != nil || ticket == nil {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Ticket not found"})
+		return
+	}
+	var body string
+	if article, err := repository.NewArticleRepository(db).GetLatestCustomerArticleForTicket(uint(ticketID)); err == nil && article != nil {
+		switch b := article.Body.(type) {
+		case string:
+			body = b
+		case []byte:
+			body = string(b)
+		}
+	}
+
+	svc := service.NewKBService(repository.NewKBRepository(db))
+	suggestions, err := svc.SuggestArticles(c.Request.Context(), ticket.Title, body, nil, limit)
+	if err != nil {
+		log.Printf("kb api: suggestions for ticket %d failed: %v", ticketID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to load suggestions"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"ticket_id":   ticketID,
+			"count":       len(suggestions),
+			"suggestions": suggestions,
+		},
+	})
+}
+
+func (r kbArticleRequest) toArticle() *models.KBArticle {
+	return &models.KBArticle{
+		CategoryID: r.CategoryID,
+		Title:      r.Title,
+		Body:       r.Body,
+		Keywords:   r.Keywords,
+		Visibility: models.KBVisibility(r.Visibility),
+		ValidID:    1,
+	}
+}
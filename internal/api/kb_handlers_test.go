@@ -0,0 +1,176 @@
+package api
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/testutil"
+)
+
+func newKBTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db := testutil.UseMigratedDB(t)
+	for _, stmt := range []string{
+		`INSERT INTO users (id, login, pw, first_name, last_name, valid_id, create_time, create_by, change_time, change_by)
+			VALUES (1, 'root@localhost', 'x', 'Admin', 'OTRS', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+		`INSERT INTO group_user (user_id, group_id, permission_key, create_time, create_by, change_time, change_by)
+			SELECT 7, id, 'rw', CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1 FROM groups WHERE name = 'kb_editor'`,
+		`INSERT INTO group_user (user_id, group_id, permission_key, create_time, create_by, change_time, change_by)
+			VALUES (8, 1, 'rw', CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+		`INSERT INTO kb_category (id, name, create_time, create_by, change_time, change_by)
+			VALUES (1, 'Network', CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+		`INSERT INTO kb_article (id, category_id, title, body, keywords, visibility, version, valid_id,
+			create_time, create_by, change_time, change_by) VALUES
+			(1, 1, 'VPN setup', 'Install the client', 'vpn', 'public', 1, 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1),
+			(2, 1, 'VPN gateway', 'Gateway passwords', 'vpn', 'internal', 1, 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1),
+			(3, 1, 'VPN for customers', 'Portal login', 'vpn', 'customer', 1, 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+	} {
+		_, err := db.Exec(stmt)
+		require.NoError(t, err, stmt)
+	}
+	return db
+}
+
+func TestHandleCreateKBArticleAPI_RejectsCustomers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.POST("/api/v1/kb/articles", func(c *gin.Context) {
+		c.Set("user_role", "Customer")
+		HandleCreateKBArticleAPI(c)
+	})
+
+	body := `{"category_id": 1, "title": "VPN setup", "visibility": "public"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/kb/articles", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestHandleCreateKBArticleAPI_Validation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	for name, body := range map[string]string{
+		"missing title":    `{"category_id": 1}`,
+		"missing category": `{"title": "VPN setup"}`,
+		"malformed json":   `{"title": `,
+	} {
+		t.Run(name, func(t *testing.T) {
+			router := gin.New()
+			router.POST("/api/v1/kb/articles", func(c *gin.Context) {
+				c.Set("user_role", "Admin")
+				HandleCreateKBArticleAPI(c)
+			})
+
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/kb/articles", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Contains(t, w.Body.String(), "Invalid article request")
+		})
+	}
+}
+
+func TestKBVisibilitiesFor(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Set("user_role", "Agent")
+	assert.Nil(t, kbVisibilitiesFor(c))
+
+	c.Set("user_role", "Customer")
+	assert.Equal(t, []models.KBVisibility{models.KBVisibilityCustomer, models.KBVisibilityPublic}, kbVisibilitiesFor(c))
+}
+
+func TestKBRequireEditor(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	newKBTestDB(t)
+
+	for name, tt := range map[string]struct {
+		role   string
+		userID int
+		want   bool
+	}{
+		"admin":       {role: "Admin", userID: 1, want: true},
+		"kb editor":   {role: "Agent", userID: 7, want: true},
+		"other agent": {role: "Agent", userID: 8, want: false},
+		"customer":    {role: "Customer", userID: 7, want: false},
+	} {
+		t.Run(name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Set("user_role", tt.role)
+			c.Set("user_id", tt.userID)
+
+			assert.Equal(t, tt.want, kbRequireEditor(c))
+			if !tt.want {
+				assert.Equal(t, http.StatusForbidden, w.Code)
+			}
+		})
+	}
+}
+
+func TestHandleDeleteKBArticleAPI_RequiresEditor(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	newKBTestDB(t)
+
+	router := gin.New()
+	router.DELETE("/api/v1/kb/articles/:id", func(c *gin.Context) {
+		c.Set("user_role", "Agent")
+		c.Set("user_id", 8)
+		HandleDeleteKBArticleAPI(c)
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/api/v1/kb/articles/1", nil))
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "editor access required")
+}
+
+func TestPublicKBArticlesAPI(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	newKBTestDB(t)
+
+	// No auth middleware: the public routes run without a user in the context
+	router := gin.New()
+	router.GET("/api/v1/public/kb/articles", HandleListPublicKBArticlesAPI)
+	router.GET("/api/v1/public/kb/articles/:id", HandleGetPublicKBArticleAPI)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	w := get("/api/v1/public/kb/articles?q=vpn")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "VPN setup")
+	assert.NotContains(t, w.Body.String(), "VPN gateway")
+	assert.NotContains(t, w.Body.String(), "VPN for customers")
+
+	w = get("/api/v1/public/kb/articles?visibility=internal")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "VPN gateway")
+
+	w = get("/api/v1/public/kb/articles/1")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "Install the client")
+
+	for _, id := range []string{"2", "3"} {
+		w = get("/api/v1/public/kb/articles/" + id)
+		assert.Equal(t, http.StatusNotFound, w.Code, "article %s is not public", id)
+	}
+}
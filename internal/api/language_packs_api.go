@@ -0,0 +1,233 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/i18n"
+	"github.com/goatkit/goatflow/internal/service"
+)
+
+// languagePackService returns the service, writing 503 when the database is
+// unavailable.
+func languagePackService(c *gin.Context) *service.LanguagePackService {
+	db, err := database.GetDB()
+	if err != nil || db == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"success": false, "error": "Database unavailable"})
+		return nil
+	}
+	return service.NewLanguagePackService(db)
+}
+
+// languagePackError maps LanguagePackService errors to responses.
+func languagePackError(c *gin.Context, err error, action string) {
+	switch {
+	case errors.Is(err, service.ErrLanguagePackNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Language pack not found"})
+	case errors.Is(err, service.ErrLanguagePackInvalid):
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+	default:
+		log.Printf("language packs api: %s failed: %v", action, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to " + action})
+	}
+}
+
+// HandleListLanguagePacksAPI handles GET /api/v1/admin/language-packs.
+//
+//	@Summary		List language packs
+//	@Description	Returns the imported language packs and the languages currently supported.
+//	@Tags			Language Packs
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}	"Language packs"
+//	@Security		BearerAuth
+//	@Router			/admin/language-packs [get]
+func HandleListLanguagePacksAPI(c *gin.Context) {
+	svc := languagePackService(c)
+	if svc == nil {
+		return
+	}
+	packs, err := svc.List(c.Request.Context())
+	if err != nil {
+		languagePackError(c, err, "load language packs")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{
+		"packs":     packs,
+		"languages": i18n.GetInstance().GetSupportedLanguages(),
+	}})
+}
+
+// HandleImportLanguagePackAPI handles POST /api/v1/admin/language-packs/:lang.
+//
+//	@Summary		Import language pack
+//	@Description	Imports translations for a language from a JSON (nested or flat keys) or gettext PO file, uploaded as "file" or sent as the request body. The default mode merges into the language's pack; mode=replace replaces it. Changes apply without a restart.
+//	@Tags			Language Packs
+//	@Accept			multipart/form-data,application/json,text/x-gettext-translation
+//	@Produce		json
+//	@Param			lang	path		string	true	"Language code, e.g. de or pt-BR"
+//	@Param			format	query		string	false	"json or po (default from the file name, else json)"
+//	@Param			mode	query		string	false	"merge (default) or replace"
+//	@Param			file	formData	file	false	"Language pack file"
+//	@Success		201		{object}	map[string]interface{}	"Imported pack with placeholder warnings"
+//	@Failure		400		{object}	map[string]interface{}	"Invalid language pack"
+//	@Security		BearerAuth
+//	@Router			/admin/language-packs/{lang} [post]
+func HandleImportLanguagePackAPI(c *gin.Context) {
+	mode := c.DefaultQuery("mode", "merge")
+	if mode != "merge" && mode != "replace" {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "mode must be merge or replace"})
+		return
+	}
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, service.MaxLanguagePackSize+64<<10)
+
+	format := c.Query("format")
+	var data []byte
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		content, header, err := readFormFile(c, "file")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "File is required"})
+			return
+		}
+		if format == "" && strings.EqualFold(filepath.Ext(header.Filename), ".po") {
+			format = service.LanguagePackPO
+		}
+		data = content
+	} else {
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid request body"})
+			return
+		}
+		data = body
+	}
+	if format == "" {
+		format = service.LanguagePackJSON
+	}
+
+	svc := languagePackService(c)
+	if svc == nil {
+		return
+	}
+	result, err := svc.Import(c.Request.Context(), c.Param("lang"), format, data, mode == "replace", GetUserIDFromCtx(c, 1))
+	if err != nil {
+		languagePackError(c, err, "import language pack")
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"success": true, "data": result})
+}
+
+// HandleExportLanguagePackAPI handles GET /api/v1/admin/language-packs/:lang/export.
+//
+//	@Summary		Export language pack
+//	@Description	Downloads the translations of a language as JSON or gettext PO: the built-in translations with its pack applied (scope=all), or only its pack (scope=pack). PO files carry the English text as msgid.
+//	@Tags			Language Packs
+//	@Produce		json,text/x-gettext-translation
+//	@Param			lang	path	string	true	"Language code"
+//	@Param			format	query	string	false	"json (default) or po"
+//	@Param			scope	query	string	false	"all (default) or pack"
+//	@Success		200		{file}	file	"Language pack file"
+//	@Failure		404		{object}	map[string]interface{}	"Language pack not found"
+//	@Security		BearerAuth
+//	@Router			/admin/language-packs/{lang}/export [get]
+func HandleExportLanguagePackAPI(c *gin.Context) {
+	svc := languagePackService(c)
+	if svc == nil {
+		return
+	}
+	lang := c.Param("lang")
+	format := c.DefaultQuery("format", service.LanguagePackJSON)
+	data, err := svc.Export(c.Request.Context(), lang, format, c.DefaultQuery("scope", "all"))
+	if err != nil {
+		languagePackError(c, err, "export language pack")
+		return
+	}
+	contentType := "application/json"
+	if format == service.LanguagePackPO {
+		contentType = "text/x-gettext-translation; charset=utf-8"
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", lang+"."+format))
+	c.Data(http.StatusOK, contentType, data)
+}
+
+// HandleDeleteLanguagePackAPI handles DELETE /api/v1/admin/language-packs/:lang.
+//
+//	@Summary		Delete language pack
+//	@Description	Removes the pack of a language; it falls back to its built-in translations, or is no longer offered if it has none.
+//	@Tags			Language Packs
+//	@Produce		json
+//	@Param			lang	path		string	true	"Language code"
+//	@Success		200		{object}	map[string]interface{}	"Deleted"
+//	@Failure		404		{object}	map[string]interface{}	"Language pack not found"
+//	@Security		BearerAuth
+//	@Router			/admin/language-packs/{lang} [delete]
+func HandleDeleteLanguagePackAPI(c *gin.Context) {
+	svc := languagePackService(c)
+	if svc == nil {
+		return
+	}
+	if err := svc.Delete(c.Request.Context(), c.Param("lang")); err != nil {
+		languagePackError(c, err, "delete language pack")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// HandleReloadLanguagePacksAPI handles POST /api/v1/admin/language-packs/reload.
+//
+//	@Summary		Reload language packs
+//	@Description	Applies the stored language packs now instead of at the next periodic check, e.g. after editing the table directly.
+//	@Tags			Language Packs
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}	"Reloaded"
+//	@Security		BearerAuth
+//	@Router			/admin/language-packs/reload [post]
+func HandleReloadLanguagePacksAPI(c *gin.Context) {
+	svc := languagePackService(c)
+	if svc == nil {
+		return
+	}
+	if err := svc.Reload(c.Request.Context()); err != nil {
+		languagePackError(c, err, "reload language packs")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{
+		"languages": i18n.GetInstance().GetSupportedLanguages(),
+	}})
+}
+
+// HandleListMissingTranslationsAPI handles GET /api/v1/admin/i18n/missing.
+//
+//	@Summary		Missing translation report
+//	@Description	Lists the keys looked up at runtime without a translation in the requested language since the start or the last reset, most requested first. fallback tells whether the default language had the key.
+//	@Tags			Language Packs
+//	@Produce		json
+//	@Param			lang	query		string	false	"Only this language"
+//	@Success		200		{object}	map[string]interface{}	"Missing translations"
+//	@Security		BearerAuth
+//	@Router			/admin/i18n/missing [get]
+func HandleListMissingTranslationsAPI(c *gin.Context) {
+	missing := i18n.GetInstance().MissingTranslations(c.Query("lang"))
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": missing})
+}
+
+// HandleResetMissingTranslationsAPI handles DELETE /api/v1/admin/i18n/missing.
+//
+//	@Summary		Reset missing translation report
+//	@Description	Clears the missing translation report, e.g. after importing a language pack.
+//	@Tags			Language Packs
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}	"Reset"
+//	@Security		BearerAuth
+//	@Router			/admin/i18n/missing [delete]
+func HandleResetMissingTranslationsAPI(c *gin.Context) {
+	i18n.GetInstance().ResetMissingTranslations()
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/i18n"
+)
+
+func TestLanguagePackHandlers_InvalidRequest(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.POST("/api/v1/admin/language-packs/:lang", HandleImportLanguagePackAPI)
+
+	for _, tc := range []struct {
+		path        string
+		contentType string
+		want        string
+	}{
+		{"/api/v1/admin/language-packs/de?mode=overwrite", "application/json", "mode must be merge or replace"},
+		{"/api/v1/admin/language-packs/de", "multipart/form-data; boundary=x", "File is required"},
+	} {
+		t.Run(tc.path, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tc.path, strings.NewReader(`{}`))
+			req.Header.Set("Content-Type", tc.contentType)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Contains(t, w.Body.String(), tc.want)
+		})
+	}
+}
+
+func TestMissingTranslationsHandlers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.GET("/api/v1/admin/i18n/missing", HandleListMissingTranslationsAPI)
+	router.DELETE("/api/v1/admin/i18n/missing", HandleResetMissingTranslationsAPI)
+
+	tr := i18n.GetInstance()
+	tr.ResetMissingTranslations()
+	t.Cleanup(tr.ResetMissingTranslations)
+	tr.T("de", "missing_translations_test.key")
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/admin/i18n/missing?lang=de", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp struct {
+		Data []i18n.MissingTranslation `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Len(t, resp.Data, 1)
+	assert.Equal(t, "missing_translations_test.key", resp.Data[0].Key)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodDelete, "/api/v1/admin/i18n/missing", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, tr.MissingTranslations(""))
+}
@@ -0,0 +1,79 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+
+	"github.com/goatkit/goatflow/internal/config"
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/ldap"
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/service"
+)
+
+// ldapAccounts returns the LDAP account service for the configured
+// directories, or nil when none serves the frontend.
+func ldapAccounts(db *sql.DB, frontend string) *service.LDAPAccountService {
+	cfg := config.Get()
+	if cfg == nil || len(cfg.Auth.LDAP.Directories) == 0 {
+		return nil
+	}
+	dirs, problems := ldap.DirectoriesFromConfig(cfg.Auth.LDAP.Directories)
+	for _, p := range problems {
+		log.Printf("ldap: skipping directory %s", p)
+	}
+	svc := service.NewLDAPAccountService(db, dirs)
+	if !svc.Enabled(frontend) {
+		return nil
+	}
+	return svc
+}
+
+// ldapLoginFailed logs directory errors other than a plain wrong login.
+func ldapLoginFailed(frontend, login string, err error) {
+	if errors.Is(err, ldap.ErrUserNotFound) || errors.Is(err, ldap.ErrInvalidCredentials) {
+		return
+	}
+	log.Printf("ldap: %s login for %s failed: %v", frontend, login, err)
+}
+
+// authenticateLDAPAgent checks an agent password against the agent
+// directories. It returns the agent's ID and login on success.
+func authenticateLDAPAgent(ctx context.Context, db *sql.DB, username, password string) (int, string, bool) {
+	svc := ldapAccounts(db, ldap.FrontendAgent)
+	if svc == nil {
+		return 0, "", false
+	}
+	userID, login, err := svc.AuthenticateAgent(ctx, username, password)
+	if err != nil {
+		ldapLoginFailed(ldap.FrontendAgent, username, err)
+		return 0, "", false
+	}
+	return userID, login, true
+}
+
+// authenticateLDAPCustomer checks a customer password against the customer
+// directories and returns the customer user as the database provider would.
+func authenticateLDAPCustomer(ctx context.Context, db *sql.DB, username, password string) (*models.User, bool) {
+	svc := ldapAccounts(db, ldap.FrontendCustomer)
+	if svc == nil {
+		return nil, false
+	}
+	login, err := svc.AuthenticateCustomer(ctx, username, password)
+	if err != nil {
+		ldapLoginFailed(ldap.FrontendCustomer, username, err)
+		return nil, false
+	}
+	user := &models.User{Login: login, Role: "Customer"}
+	var id int64
+	if err := db.QueryRowContext(ctx, database.ConvertPlaceholders(
+		"SELECT id, email, first_name, last_name, valid_id FROM customer_user WHERE login = ?"), login).
+		Scan(&id, &user.Email, &user.FirstName, &user.LastName, &user.ValidID); err != nil {
+		log.Printf("ldap: load customer user %s: %v", login, err)
+		return nil, false
+	}
+	user.ID = uint(id)
+	return user, true
+}
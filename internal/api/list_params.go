@@ -0,0 +1,265 @@
+package api
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// errInvalidListCursor is returned for cursors that cannot be decoded or were
+// issued for a different sort order than the current request.
+var errInvalidListCursor = errors.New("invalid cursor")
+
+// listSortKind tells how a cursor's sort value is passed back to the database.
+type listSortKind int
+
+const (
+	listSortString listSortKind = iota
+	listSortInt
+	listSortTime
+)
+
+// listSort describes a column a list endpoint can be ordered by. Field is the
+// key holding the column's value in each response item.
+type listSort struct {
+	Column string
+	Field  string
+	Kind   listSortKind
+}
+
+// listCursor is the position after the last item of a page. It is handed to
+// clients as an opaque base64 string.
+type listCursor struct {
+	Sort  string `json:"s"`
+	Order string `json:"o"`
+	Value string `json:"v,omitempty"`
+	ID    int64  `json:"id"`
+}
+
+// listCursorParam reports whether the request asked for cursor pagination and
+// decodes its cursor. An empty cursor parameter starts at the first page.
+func listCursorParam(c *gin.Context, sortKey, order string) (bool, *listCursor, error) {
+	raw, ok := c.GetQuery("cursor")
+	if !ok {
+		return false, nil, nil
+	}
+	if raw == "" {
+		return true, nil, nil
+	}
+	cur, err := decodeListCursor(raw)
+	if err != nil || cur.Sort != sortKey || cur.Order != order {
+		return true, nil, errInvalidListCursor
+	}
+	return true, cur, nil
+}
+
+func encodeListCursor(cur listCursor) string {
+	b, _ := json.Marshal(cur) //nolint:errcheck // plain struct always marshals
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func decodeListCursor(raw string) (*listCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil {
+		return nil, errInvalidListCursor
+	}
+	var cur listCursor
+	if err := json.Unmarshal(b, &cur); err != nil || cur.ID <= 0 {
+		return nil, errInvalidListCursor
+	}
+	return &cur, nil
+}
+
+// cursorAfter returns the cursor pointing past item.
+func (s listSort) cursorAfter(sortKey, order string, item map[string]interface{}) string {
+	cur := listCursor{Sort: sortKey, Order: order, ID: listItemID(item)}
+	if s.Field != "" && s.Field != "id" {
+		if v, ok := item[s.Field]; ok && v != nil {
+			cur.Value = fmt.Sprint(v)
+		}
+	}
+	return encodeListCursor(cur)
+}
+
+// keyset returns the SQL condition selecting the rows after cur in the given
+// order, with its arguments. idColumn breaks ties between equal sort values.
+func (s listSort) keyset(cur *listCursor, idColumn, order string) (string, []interface{}, error) {
+	op := ">"
+	if order == "desc" {
+		op = "<"
+	}
+	if s.Column == "" || s.Column == idColumn {
+		return fmt.Sprintf("%s %s ?", idColumn, op), []interface{}{cur.ID}, nil
+	}
+	v, err := s.arg(cur.Value)
+	if err != nil {
+		return "", nil, err
+	}
+	cond := fmt.Sprintf("(%s %s ? OR (%s = ? AND %s %s ?))", s.Column, op, s.Column, idColumn, op)
+	return cond, []interface{}{v, v, cur.ID}, nil
+}
+
+func (s listSort) arg(value string) (interface{}, error) {
+	switch s.Kind {
+	case listSortInt:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, errInvalidListCursor
+		}
+		return n, nil
+	case listSortTime:
+		for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05"} {
+			if t, err := time.Parse(layout, value); err == nil {
+				return t, nil
+			}
+		}
+		return nil, errInvalidListCursor
+	default:
+		return value, nil
+	}
+}
+
+func listItemID(item map[string]interface{}) int64 {
+	switch v := item["id"].(type) {
+	case int:
+		return int64(v)
+	case int64:
+		return v
+	case int32:
+		return int64(v)
+	case uint:
+		return int64(v)
+	}
+	return 0
+}
+
+// listPlaceholders returns n comma-separated placeholders for an IN clause.
+func listPlaceholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?,", n), ",")
+}
+
+// parseListNames parses a comma-separated query parameter such as fields= or
+// expand= and rejects names outside allowed. It returns nil when the
+// parameter is absent.
+func parseListNames(c *gin.Context, param string, allowed []string) (map[string]bool, error) {
+	raw := strings.TrimSpace(c.Query(param))
+	if raw == "" {
+		return nil, nil
+	}
+	known := make(map[string]bool, len(allowed))
+	for _, name := range allowed {
+		known[name] = true
+	}
+	names := map[string]bool{}
+	for _, name := range strings.Split(raw, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if !known[name] {
+			return nil, fmt.Errorf("unknown %s value %q (allowed: %s)", param, name, strings.Join(allowed, ", "))
+		}
+		names[name] = true
+	}
+	return names, nil
+}
+
+// selectListFields keeps the requested fields of item, plus its id. Expanded
+// resources are kept as well so fields= and expand= can be combined.
+func selectListFields(item map[string]interface{}, fields, expand map[string]bool) map[string]interface{} {
+	if fields == nil {
+		return item
+	}
+	out := make(map[string]interface{}, len(fields)+len(expand)+1)
+	for k, v := range item {
+		if k == "id" || fields[k] || expand[k] {
+			out[k] = v
+		}
+	}
+	return out
+}
+
+// listPageURL returns the request path and query with the given parameters
+// replaced. Parameters set to "" are removed.
+func listPageURL(c *gin.Context, set map[string]string) string {
+	q := c.Request.URL.Query()
+	for k, v := range set {
+		if v == "" {
+			q.Del(k)
+		} else {
+			q.Set(k, v)
+		}
+	}
+	if enc := q.Encode(); enc != "" {
+		return c.Request.URL.Path + "?" + enc
+	}
+	return c.Request.URL.Path
+}
+
+// setListLinkHeader sets an RFC 5988 Link header. links holds relation and
+// URL pairs; pairs with an empty URL are skipped.
+func setListLinkHeader(c *gin.Context, links ...[2]string) {
+	parts := make([]string, 0, len(links))
+	for _, l := range links {
+		if l[1] != "" {
+			parts = append(parts, fmt.Sprintf("<%s>; rel=%q", l[1], l[0]))
+		}
+	}
+	if len(parts) > 0 {
+		c.Header("Link", strings.Join(parts, ", "))
+	}
+}
+
+// setPageLinkHeader sets first, prev, next and last links for page-based
+// pagination.
+func setPageLinkHeader(c *gin.Context, page, totalPages int) {
+	if totalPages < 1 {
+		totalPages = 1
+	}
+	pageURL := func(p int) string {
+		return listPageURL(c, map[string]string{"page": strconv.Itoa(p), "offset": ""})
+	}
+	var prev, next string
+	if page > 1 {
+		prev = pageURL(page - 1)
+	}
+	if page < totalPages {
+		next = pageURL(page + 1)
+	}
+	setListLinkHeader(c,
+		[2]string{"first", pageURL(1)},
+		[2]string{"prev", prev},
+		[2]string{"next", next},
+		[2]string{"last", pageURL(totalPages)},
+	)
+}
+
+// setCursorLinkHeader sets first and next links for cursor pagination.
+// nextCursor is empty on the last page.
+func setCursorLinkHeader(c *gin.Context, nextCursor string) {
+	// An empty cursor parameter selects the first page in cursor mode.
+	first := listPageURL(c, map[string]string{"cursor": "", "page": "", "offset": ""})
+	if strings.Contains(first, "?") {
+		first += "&cursor="
+	} else {
+		first += "?cursor="
+	}
+	var next string
+	if nextCursor != "" {
+		next = listPageURL(c, map[string]string{"cursor": nextCursor, "page": "", "offset": ""})
+	}
+	setListLinkHeader(c, [2]string{"first", first}, [2]string{"next", next})
+}
+
+// listParamError writes a 400 response for a bad pagination, fields or
+// expand parameter.
+func listParamError(c *gin.Context, err error) {
+	c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+}
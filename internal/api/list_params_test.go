@@ -0,0 +1,84 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func listParamsContext(target string) (*gin.Context, *httptest.ResponseRecorder) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, target, nil)
+	return c, w
+}
+
+func TestListCursor_RoundTrip(t *testing.T) {
+	sort := listSort{Column: "t.create_time", Field: "created_at", Kind: listSortTime}
+	item := map[string]interface{}{"id": int64(42), "created_at": "2025-03-01T10:00:00Z"}
+	raw := sort.cursorAfter("created", "desc", item)
+
+	c, _ := listParamsContext("/api/v1/tickets?cursor=" + raw + "&sort=created")
+	cursorMode, cur, err := listCursorParam(c, "created", "desc")
+	require.NoError(t, err)
+	require.True(t, cursorMode)
+	assert.Equal(t, int64(42), cur.ID)
+
+	cond, args, err := sort.keyset(cur, "t.id", "desc")
+	require.NoError(t, err)
+	assert.Equal(t, "(t.create_time < ? OR (t.create_time = ? AND t.id < ?))", cond)
+	assert.Equal(t, time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC), args[0])
+	assert.Equal(t, int64(42), args[2])
+
+	// A cursor issued for another order is rejected.
+	_, _, err = listCursorParam(c, "created", "asc")
+	assert.ErrorIs(t, err, errInvalidListCursor)
+
+	c, _ = listParamsContext("/api/v1/tickets?cursor=not-a-cursor")
+	_, _, err = listCursorParam(c, "created", "desc")
+	assert.ErrorIs(t, err, errInvalidListCursor)
+
+	c, _ = listParamsContext("/api/v1/tickets?cursor=")
+	cursorMode, cur, err = listCursorParam(c, "created", "desc")
+	require.NoError(t, err)
+	assert.True(t, cursorMode)
+	assert.Nil(t, cur)
+}
+
+func TestListFieldsAndExpand(t *testing.T) {
+	c, _ := listParamsContext("/api/v1/tickets?fields=title,+tn&expand=queue")
+	fields, err := parseListNames(c, "fields", ticketListFields)
+	require.NoError(t, err)
+	expand, err := parseListNames(c, "expand", ticketListExpansions)
+	require.NoError(t, err)
+
+	item := map[string]interface{}{"id": 1, "tn": "100", "title": "x", "queue_id": 2, "queue": map[string]interface{}{"id": 2}}
+	assert.Equal(t, map[string]interface{}{"id": 1, "tn": "100", "title": "x", "queue": map[string]interface{}{"id": 2}},
+		selectListFields(item, fields, expand))
+
+	c, _ = listParamsContext("/api/v1/tickets?fields=password")
+	_, err = parseListNames(c, "fields", ticketListFields)
+	assert.ErrorContains(t, err, `unknown fields value "password"`)
+}
+
+func TestListLinkHeaders(t *testing.T) {
+	c, w := listParamsContext("/api/v1/users?page=2&per_page=10")
+	setPageLinkHeader(c, 2, 3)
+	assert.Equal(t, `</api/v1/users?page=1&per_page=10>; rel="first", </api/v1/users?page=1&per_page=10>; rel="prev", `+
+		`</api/v1/users?page=3&per_page=10>; rel="next", </api/v1/users?page=3&per_page=10>; rel="last"`, w.Header().Get("Link"))
+
+	c, w = listParamsContext("/api/v1/queues?cursor=abc&fields=name")
+	setCursorLinkHeader(c, "def")
+	assert.Equal(t, `</api/v1/queues?fields=name&cursor=>; rel="first", </api/v1/queues?cursor=def&fields=name>; rel="next"`,
+		w.Header().Get("Link"))
+
+	c, w = listParamsContext("/api/v1/queues?cursor=abc")
+	setCursorLinkHeader(c, "")
+	assert.Equal(t, `</api/v1/queues?cursor=>; rel="first"`, w.Header().Get("Link"))
+}
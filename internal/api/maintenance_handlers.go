@@ -0,0 +1,323 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/flosch/pongo2/v6"
+	"github.com/gin-gonic/gin"
+
+	"github.com/goatkit/goatflow/internal/config"
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/service"
+)
+
+// announcementRequest is the JSON body accepted by the announcement
+// create/update handlers.
+type announcementRequest struct {
+	Message   string     `json:"message" binding:"required"`
+	Audience  string     `json:"audience"`
+	Severity  string     `json:"severity"`
+	StartTime *time.Time `json:"start_time"`
+	StopTime  *time.Time `json:"stop_time"`
+	ValidID   int        `json:"valid_id"`
+}
+
+func (r *announcementRequest) announcement() *models.SystemAnnouncement {
+	return &models.SystemAnnouncement{
+		Message:   r.Message,
+		Audience:  r.Audience,
+		Severity:  r.Severity,
+		StartTime: r.StartTime,
+		StopTime:  r.StopTime,
+		ValidID:   r.ValidID,
+	}
+}
+
+// newSystemMaintenanceService creates a system maintenance service with the
+// configured notice period and default messages.
+func newSystemMaintenanceService(db *sql.DB) *service.SystemMaintenanceService {
+	svc := service.NewSystemMaintenanceService(db)
+	if cfg := config.Get(); cfg != nil {
+		svc.SetDefaults(service.MaintenanceDefaults{
+			UpcomingMinutes: cfg.Maintenance.TimeNotifyUpcomingMinutes,
+			NotifyMessage:   cfg.Maintenance.DefaultNotifyMessage,
+			LoginMessage:    cfg.Maintenance.DefaultLoginMessage,
+		})
+	}
+	return svc
+}
+
+// systemMaintenanceService returns the service, writing 503 when the
+// database is unavailable.
+func systemMaintenanceService(c *gin.Context) *service.SystemMaintenanceService {
+	db, err := database.GetDB()
+	if err != nil || db == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"success": false, "error": "Database unavailable"})
+		return nil
+	}
+	return newSystemMaintenanceService(db)
+}
+
+// announcementError maps SystemMaintenanceService errors to responses.
+func announcementError(c *gin.Context, err error, action string) {
+	switch {
+	case errors.Is(err, service.ErrAnnouncementNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Announcement not found"})
+	case errors.Is(err, service.ErrAnnouncementMessage),
+		errors.Is(err, service.ErrAnnouncementAudience),
+		errors.Is(err, service.ErrAnnouncementSeverity),
+		errors.Is(err, service.ErrAnnouncementWindow):
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+	default:
+		log.Printf("maintenance api: %s failed: %v", action, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to " + action})
+	}
+}
+
+// announcementID parses the :id path parameter, writing 400 when it is invalid.
+func announcementID(c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid announcement ID"})
+		return 0, false
+	}
+	return id, true
+}
+
+// maintenanceLoginBlock returns the message turning an account away while a
+// maintenance window is active, or "" when it may sign in. A failed lookup
+// lets the login through.
+func maintenanceLoginBlock(ctx context.Context, db *sql.DB, kind service.PasswordAccountType, userID int) string {
+	if db == nil {
+		return ""
+	}
+	err := newSystemMaintenanceService(db).CheckLogin(ctx, kind, userID)
+	var blocked *service.MaintenanceLoginError
+	if errors.As(err, &blocked) {
+		return blocked.Message
+	}
+	if err != nil {
+		log.Printf("Failed to check maintenance for %s %d: %v", kind, userID, err)
+	}
+	return ""
+}
+
+// handleAdminMaintenance renders the maintenance overview: the current
+// state, the scheduled windows and the announcement editor.
+func handleAdminMaintenance(c *gin.Context) {
+	db, err := database.GetDB()
+	if err != nil || db == nil {
+		sendErrorResponse(c, http.StatusServiceUnavailable, "Database unavailable")
+		return
+	}
+	svc := newSystemMaintenanceService(db)
+	ctx := c.Request.Context()
+
+	status, err := svc.Status(ctx, "")
+	if err != nil {
+		log.Printf("maintenance: load status failed: %v", err)
+		sendErrorResponse(c, http.StatusInternalServerError, "Failed to load maintenance status")
+		return
+	}
+	windows, err := svc.Windows(ctx)
+	if err != nil {
+		log.Printf("maintenance: list windows failed: %v", err)
+		sendErrorResponse(c, http.StatusInternalServerError, "Failed to load maintenance windows")
+		return
+	}
+	announcements, err := svc.ListAnnouncements(ctx)
+	if err != nil {
+		log.Printf("maintenance: list announcements failed: %v", err)
+		sendErrorResponse(c, http.StatusInternalServerError, "Failed to load announcements")
+		return
+	}
+
+	getPongo2Renderer().HTML(c, http.StatusOK, "pages/admin/maintenance.pongo2", pongo2.Context{
+		"Title":             "Maintenance",
+		"MaintenanceStatus": status,
+		"Windows":           windows,
+		"Announcements":     announcements,
+		"User":              getUserMapForTemplate(c),
+		"ActivePage":        "admin",
+	})
+}
+
+// HandleMaintenanceStatusAPI handles GET /api/v1/status/maintenance.
+//
+//	@Summary		Maintenance status
+//	@Description	Public endpoint for status pages: the active and upcoming maintenance windows and the announcements shown to customers.
+//	@Tags			System Maintenance
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}	"Maintenance status"
+//	@Router			/status/maintenance [get]
+func HandleMaintenanceStatusAPI(c *gin.Context) {
+	svc := systemMaintenanceService(c)
+	if svc == nil {
+		return
+	}
+	status, err := svc.Status(c.Request.Context(), models.AnnouncementAudienceCustomer)
+	if err != nil {
+		log.Printf("maintenance api: load status failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to load maintenance status"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": status})
+}
+
+// HandleListAnnouncementsAPI handles GET /api/v1/admin/announcements.
+//
+//	@Summary		List system announcements
+//	@Description	Every announcement, newest first. active=true lists only those shown now.
+//	@Tags			System Maintenance
+//	@Produce		json
+//	@Param			active	query		bool	false	"Only announcements shown now"
+//	@Success		200		{object}	map[string]interface{}	"Announcements"
+//	@Security		BearerAuth
+//	@Router			/admin/announcements [get]
+func HandleListAnnouncementsAPI(c *gin.Context) {
+	svc := systemMaintenanceService(c)
+	if svc == nil {
+		return
+	}
+	var announcements []*models.SystemAnnouncement
+	var err error
+	if c.Query("active") == "true" {
+		announcements, err = svc.ActiveAnnouncements(c.Request.Context(), "")
+	} else {
+		announcements, err = svc.ListAnnouncements(c.Request.Context())
+	}
+	if err != nil {
+		announcementError(c, err, "load announcements")
+		return
+	}
+	if announcements == nil {
+		announcements = []*models.SystemAnnouncement{}
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": announcements})
+}
+
+// HandleCreateAnnouncementAPI handles POST /api/v1/admin/announcements.
+//
+//	@Summary		Create system announcement
+//	@Tags			System Maintenance
+//	@Accept			json
+//	@Produce		json
+//	@Param			announcement	body		object	true	"Announcement (message, audience, severity, start_time, stop_time, valid_id)"
+//	@Success		201				{object}	map[string]interface{}	"Announcement created"
+//	@Failure		400				{object}	map[string]interface{}	"Invalid request"
+//	@Security		BearerAuth
+//	@Router			/admin/announcements [post]
+func HandleCreateAnnouncementAPI(c *gin.Context) {
+	var req announcementRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid announcement: " + err.Error()})
+		return
+	}
+	svc := systemMaintenanceService(c)
+	if svc == nil {
+		return
+	}
+	a, err := svc.CreateAnnouncement(c.Request.Context(), req.announcement(), GetUserIDFromCtx(c, 1))
+	if err != nil {
+		announcementError(c, err, "create announcement")
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"success": true, "data": a})
+}
+
+// HandleGetAnnouncementAPI handles GET /api/v1/admin/announcements/:id.
+//
+//	@Summary		Get system announcement
+//	@Tags			System Maintenance
+//	@Produce		json
+//	@Param			id	path		int	true	"Announcement ID"
+//	@Success		200	{object}	map[string]interface{}	"Announcement"
+//	@Failure		404	{object}	map[string]interface{}	"Announcement not found"
+//	@Security		BearerAuth
+//	@Router			/admin/announcements/{id} [get]
+func HandleGetAnnouncementAPI(c *gin.Context) {
+	id, ok := announcementID(c)
+	if !ok {
+		return
+	}
+	svc := systemMaintenanceService(c)
+	if svc == nil {
+		return
+	}
+	a, err := svc.GetAnnouncement(c.Request.Context(), id)
+	if err != nil {
+		announcementError(c, err, "load announcement")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": a})
+}
+
+// HandleUpdateAnnouncementAPI handles PUT /api/v1/admin/announcements/:id.
+//
+//	@Summary		Update system announcement
+//	@Tags			System Maintenance
+//	@Accept			json
+//	@Produce		json
+//	@Param			id				path		int		true	"Announcement ID"
+//	@Param			announcement	body		object	true	"Announcement"
+//	@Success		200				{object}	map[string]interface{}	"Announcement updated"
+//	@Failure		400				{object}	map[string]interface{}	"Invalid request"
+//	@Failure		404				{object}	map[string]interface{}	"Announcement not found"
+//	@Security		BearerAuth
+//	@Router			/admin/announcements/{id} [put]
+func HandleUpdateAnnouncementAPI(c *gin.Context) {
+	id, ok := announcementID(c)
+	if !ok {
+		return
+	}
+	var req announcementRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid announcement: " + err.Error()})
+		return
+	}
+	svc := systemMaintenanceService(c)
+	if svc == nil {
+		return
+	}
+	a := req.announcement()
+	a.ID = id
+	updated, err := svc.UpdateAnnouncement(c.Request.Context(), a, GetUserIDFromCtx(c, 1))
+	if err != nil {
+		announcementError(c, err, "update announcement")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": updated})
+}
+
+// HandleDeleteAnnouncementAPI handles DELETE /api/v1/admin/announcements/:id.
+//
+//	@Summary		Delete system announcement
+//	@Tags			System Maintenance
+//	@Produce		json
+//	@Param			id	path		int	true	"Announcement ID"
+//	@Success		200	{object}	map[string]interface{}	"Announcement deleted"
+//	@Failure		404	{object}	map[string]interface{}	"Announcement not found"
+//	@Security		BearerAuth
+//	@Router			/admin/announcements/{id} [delete]
+func HandleDeleteAnnouncementAPI(c *gin.Context) {
+	id, ok := announcementID(c)
+	if !ok {
+		return
+	}
+	svc := systemMaintenanceService(c)
+	if svc == nil {
+		return
+	}
+	if err := svc.DeleteAnnouncement(c.Request.Context(), id); err != nil {
+		announcementError(c, err, "delete announcement")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
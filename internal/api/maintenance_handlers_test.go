@@ -0,0 +1,46 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnnouncementAPI_InvalidRequests(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.POST("/api/v1/admin/announcements", HandleCreateAnnouncementAPI)
+	router.GET("/api/v1/admin/announcements/:id", HandleGetAnnouncementAPI)
+	router.PUT("/api/v1/admin/announcements/:id", HandleUpdateAnnouncementAPI)
+	router.DELETE("/api/v1/admin/announcements/:id", HandleDeleteAnnouncementAPI)
+
+	for _, tc := range []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		want   string
+	}{
+		{"not JSON", http.MethodPost, "/api/v1/admin/announcements", `message=hello`, "Invalid announcement"},
+		{"no message", http.MethodPost, "/api/v1/admin/announcements", `{"audience": "agent"}`, "Invalid announcement"},
+		{"bad time", http.MethodPost, "/api/v1/admin/announcements", `{"message": "x", "start_time": "tomorrow"}`, "Invalid announcement"},
+		{"bad id", http.MethodGet, "/api/v1/admin/announcements/abc", ``, "Invalid announcement ID"},
+		{"zero id", http.MethodDelete, "/api/v1/admin/announcements/0", ``, "Invalid announcement ID"},
+		{"update bad id", http.MethodPut, "/api/v1/admin/announcements/-1", `{"message": "x"}`, "Invalid announcement ID"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Contains(t, w.Body.String(), tc.want)
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package api
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/goatkit/goatflow/internal/database"
+)
+
+// HandleMigrationStatusAPI handles GET /api/v1/admin/migrations.
+//
+//	@Summary		Get schema migration status
+//	@Description	Returns the database schema version and, for every known migration, whether it was applied, when, and whether its file changed since.
+//	@Tags			System
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}	"Migration status"
+//	@Failure		503	{object}	map[string]interface{}	"Database unavailable"
+//	@Security		BearerAuth
+//	@Router			/admin/migrations [get]
+func HandleMigrationStatusAPI(c *gin.Context) {
+	db, err := database.GetDB()
+	if err != nil || db == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"success": false, "error": "Database unavailable"})
+		return
+	}
+	m, err := database.NewMigrator(db, database.GetDBDriver(), database.MigrationsFS())
+	if err != nil {
+		log.Printf("migrations api: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to load migrations"})
+		return
+	}
+	status, err := m.Status(c.Request.Context())
+	if err != nil {
+		log.Printf("migrations api: status failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to read migration status"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": status})
+}
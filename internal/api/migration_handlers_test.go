@@ -0,0 +1,37 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/testutil"
+)
+
+func TestHandleMigrationStatusAPI(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	testutil.UseMigratedDB(t)
+
+	router := gin.New()
+	router.GET("/api/v1/admin/migrations", HandleMigrationStatusAPI)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/v1/admin/migrations", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var resp struct {
+		Success bool                     `json:"success"`
+		Data    database.MigrationStatus `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.True(t, resp.Success)
+	assert.Equal(t, resp.Data.Latest, resp.Data.Version)
+	assert.Zero(t, resp.Data.Pending)
+	assert.NotEmpty(t, resp.Data.Migrations)
+}
@@ -0,0 +1,163 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/service"
+	"github.com/goatkit/goatflow/internal/sysconfig"
+)
+
+// passwordPolicyAccountType parses the :type path parameter, writing 404
+// when it is neither agent nor customer.
+func passwordPolicyAccountType(c *gin.Context) (service.PasswordAccountType, bool) {
+	kind := service.PasswordAccountType(c.Param("type"))
+	if kind != service.PasswordAccountAgent && kind != service.PasswordAccountCustomer {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Password policies exist for agent and customer accounts"})
+		return "", false
+	}
+	return kind, true
+}
+
+// passwordPolicyService creates a password policy service, writing 503
+// when the database is unavailable.
+func passwordPolicyService(c *gin.Context) *service.PasswordPolicyService {
+	db, err := database.GetDB()
+	if err != nil || db == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"success": false, "error": "Database unavailable"})
+		return nil
+	}
+	return service.NewPasswordPolicyService(db)
+}
+
+// HandleGetPasswordPolicyAPI handles GET /api/v1/password-policies/:type.
+//
+//	@Summary		Get a password policy
+//	@Description	Returns the password policy of agent or customer accounts.
+//	@Tags			Password Policies
+//	@Produce		json
+//	@Param			type	path		string					true	"agent or customer"
+//	@Success		200		{object}	map[string]interface{}	"Password policy"
+//	@Failure		404		{object}	map[string]interface{}	"Unknown account type"
+//	@Security		BearerAuth
+//	@Router			/password-policies/{type} [get]
+func HandleGetPasswordPolicyAPI(c *gin.Context) {
+	kind, ok := passwordPolicyAccountType(c)
+	if !ok {
+		return
+	}
+	svc := passwordPolicyService(c)
+	if svc == nil {
+		return
+	}
+	policy, err := svc.Policy(kind)
+	if err != nil {
+		log.Printf("password policy api: load %s policy failed: %v", kind, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to load password policy"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": policy})
+}
+
+// HandleUpdatePasswordPolicyAPI handles PUT /api/v1/password-policies/:type.
+//
+//	@Summary		Update a password policy
+//	@Description	Replaces the password policy of agent or customer accounts: composition rules, history, expiry, breach checks and the failed login lockout.
+//	@Tags			Password Policies
+//	@Accept			json
+//	@Produce		json
+//	@Param			type	path		string					true	"agent or customer"
+//	@Param			policy	body		object					true	"Password policy"
+//	@Success		200		{object}	map[string]interface{}	"Updated password policy"
+//	@Failure		400		{object}	map[string]interface{}	"Invalid policy"
+//	@Failure		404		{object}	map[string]interface{}	"Unknown account type"
+//	@Security		BearerAuth
+//	@Router			/password-policies/{type} [put]
+func HandleUpdatePasswordPolicyAPI(c *gin.Context) {
+	kind, ok := passwordPolicyAccountType(c)
+	if !ok {
+		return
+	}
+	var policy sysconfig.PasswordPolicy
+	if err := c.ShouldBindJSON(&policy); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid request: " + err.Error()})
+		return
+	}
+	svc := passwordPolicyService(c)
+	if svc == nil {
+		return
+	}
+	saved, err := svc.SavePolicy(kind, policy, GetUserIDFromCtx(c, 1))
+	if errors.Is(err, service.ErrPasswordPolicyInvalid) {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Printf("password policy api: save %s policy failed: %v", kind, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to save password policy"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": saved})
+}
+
+// recordLoginFailure counts a failed login towards the account lockout.
+// Logins without a local account are ignored.
+func recordLoginFailure(ctx context.Context, db *sql.DB, kind service.PasswordAccountType, login string) {
+	if db == nil || login == "" {
+		return
+	}
+	_, err := service.NewPasswordPolicyService(db).RecordLoginFailure(ctx, kind, login)
+	if err != nil && !errors.Is(err, service.ErrPasswordAccountNotFound) {
+		log.Printf("Failed to record failed login for %s %s: %v", kind, login, err)
+	}
+}
+
+// recordAPILoginFailure counts a failed API login, which may be an agent's
+// or a customer's, towards the account lockout.
+func recordAPILoginFailure(ctx context.Context, db *sql.DB, login string) {
+	if db == nil || login == "" {
+		return
+	}
+	svc := service.NewPasswordPolicyService(db)
+	_, err := svc.RecordLoginFailure(ctx, service.PasswordAccountAgent, login)
+	if errors.Is(err, service.ErrPasswordAccountNotFound) {
+		_, err = svc.RecordLoginFailure(ctx, service.PasswordAccountCustomer, login)
+	}
+	if err != nil && !errors.Is(err, service.ErrPasswordAccountNotFound) {
+		log.Printf("Failed to record failed login for %s: %v", login, err)
+	}
+}
+
+// recordLoginSuccess resets the failed login count and reports whether the
+// account's password has expired, in which case the account is held to the
+// change-password pages until it changes it (middleware.EnforcePasswordChange).
+// Accounts signed in by a directory pass checkExpiry false: their passwords
+// are not managed here.
+func recordLoginSuccess(ctx context.Context, db *sql.DB, kind service.PasswordAccountType, login string, checkExpiry bool) bool {
+	if db == nil {
+		return false
+	}
+	svc := service.NewPasswordPolicyService(db)
+	if err := svc.RecordLoginSuccess(ctx, kind, login); err != nil && !errors.Is(err, service.ErrPasswordAccountNotFound) {
+		log.Printf("Failed to reset failed logins for %s %s: %v", kind, login, err)
+	}
+	if !checkExpiry {
+		return false
+	}
+	expired, err := svc.Expired(ctx, kind, login)
+	if err != nil && !errors.Is(err, service.ErrPasswordAccountNotFound) {
+		log.Printf("Failed to check password expiry for %s %s: %v", kind, login, err)
+	}
+	if expired {
+		if err := svc.RequireChange(ctx, kind, login); err != nil {
+			log.Printf("Failed to require a password change for %s %s: %v", kind, login, err)
+		}
+	}
+	return expired
+}
@@ -0,0 +1,42 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPasswordPolicyAPI_InvalidRequests(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.GET("/api/v1/password-policies/:type", HandleGetPasswordPolicyAPI)
+	router.PUT("/api/v1/password-policies/:type", HandleUpdatePasswordPolicyAPI)
+
+	for _, tc := range []struct {
+		method, path, body string
+		code               int
+		want               string
+	}{
+		{http.MethodGet, "/api/v1/password-policies/robot", "", http.StatusNotFound, "agent and customer"},
+		{http.MethodPut, "/api/v1/password-policies/admin", `{}`, http.StatusNotFound, "agent and customer"},
+		{http.MethodPut, "/api/v1/password-policies/agent", `{"password_history":"3"}`, http.StatusBadRequest, "Invalid request"},
+	} {
+		req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, tc.code, w.Code, tc.path)
+		assert.Contains(t, w.Body.String(), tc.want, tc.path)
+	}
+}
+
+func TestGetPasswordPolicyErrorMessage_HistoryAndBreaches(t *testing.T) {
+	assert.Contains(t, getPasswordPolicyErrorMessage("history"), "used recently")
+	assert.Contains(t, getPasswordPolicyErrorMessage("breached"), "data breach")
+}
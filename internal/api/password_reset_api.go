@@ -0,0 +1,339 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/goatkit/goatflow/internal/auth"
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/service"
+	"github.com/goatkit/goatflow/internal/sysconfig"
+)
+
+// passwordResetLimiter limits forgot-password requests and failed resets
+// per client IP, on top of the per-account limit of the reset settings.
+var passwordResetLimiter = auth.NewLoginRateLimiter(10, 3600, 10*time.Minute, time.Hour)
+
+// passwordResetSent is the answer to every accepted request, so it does not
+// tell whether an account exists.
+const passwordResetSent = "If an account matches, we have sent a link to reset its password. Please check your email."
+
+// passwordResetService returns the service, writing 503 when the database
+// is unavailable.
+func passwordResetService(c *gin.Context) *service.PasswordResetService {
+	db, err := database.GetDB()
+	if err != nil || db == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"success": false, "error": "Database unavailable"})
+		return nil
+	}
+	return service.NewPasswordResetService(db)
+}
+
+// passwordResetEnabled tells the login pages whether to offer a password
+// reset.
+func passwordResetEnabled(kind service.PasswordAccountType) bool {
+	db, err := database.GetDB()
+	if err != nil || db == nil {
+		return false
+	}
+	enabled, _ := service.NewPasswordResetService(db).Enabled(kind)
+	return enabled
+}
+
+// passwordResetMessage returns the message shown for a password reset
+// error, and its status; ok is false for unexpected errors.
+func passwordResetMessage(err error) (int, string, bool) {
+	var (
+		pwErr     *service.PasswordResetPolicyError
+		lockedErr *service.PasswordResetLockedError
+	)
+	switch {
+	case errors.As(err, &pwErr):
+		return http.StatusBadRequest, getPasswordPolicyErrorMessage(pwErr.Code), true
+	case errors.As(err, &lockedErr):
+		return http.StatusTooManyRequests, fmt.Sprintf("Too many password reset requests. Please try again in %d minutes.",
+			int(time.Until(lockedErr.Until).Minutes())+1), true
+	case errors.Is(err, service.ErrPasswordResetDisabled):
+		return http.StatusNotFound, "Password reset is not available", true
+	case errors.Is(err, service.ErrPasswordResetInvalid):
+		return http.StatusBadRequest, err.Error(), true
+	case errors.Is(err, service.ErrPasswordResetLinkExpired):
+		return http.StatusGone, "This link has expired. Please request a new one.", true
+	case errors.Is(err, service.ErrPasswordResetLinkInvalid):
+		return http.StatusNotFound, "This link is invalid or was already used", true
+	}
+	return http.StatusInternalServerError, "Password reset failed, please try again later", false
+}
+
+// passwordResetError maps PasswordResetService errors to responses.
+func passwordResetError(c *gin.Context, err error, action string) {
+	var (
+		pwErr     *service.PasswordResetPolicyError
+		lockedErr *service.PasswordResetLockedError
+	)
+	switch {
+	case errors.As(err, &pwErr):
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": getPasswordPolicyErrorMessage(pwErr.Code), "code": pwErr.Code})
+	case errors.As(err, &lockedErr):
+		retry := int(time.Until(lockedErr.Until).Seconds())
+		c.JSON(http.StatusTooManyRequests, gin.H{
+			"success":         false,
+			"error":           fmt.Sprintf("too many password reset requests, try again in %d seconds", retry),
+			"retry_after_sec": retry,
+		})
+	case errors.Is(err, service.ErrPasswordResetSettings):
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+	case errors.Is(err, service.ErrPasswordResetLockoutNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Lockout not found"})
+	case errors.Is(err, service.ErrPasswordAccountType):
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+	default:
+		status, msg, ok := passwordResetMessage(err)
+		if !ok {
+			log.Printf("password reset api: %s failed: %v", action, err)
+			msg = "Failed to " + action
+		}
+		c.JSON(status, gin.H{"success": false, "error": msg})
+	}
+}
+
+// passwordResetRateLimited reports whether the client IP is blocked for the
+// action, and for how long.
+func passwordResetRateLimited(c *gin.Context, action string) (time.Duration, bool) {
+	if blocked, remaining := passwordResetLimiter.IsBlocked(c.ClientIP(), action); blocked {
+		return remaining, true
+	}
+	return 0, false
+}
+
+// writePasswordResetRateLimited writes the 429 of a blocked client IP.
+func writePasswordResetRateLimited(c *gin.Context, remaining time.Duration) {
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"success":         false,
+		"error":           fmt.Sprintf("too many attempts, try again in %d seconds", int(remaining.Seconds())),
+		"retry_after_sec": int(remaining.Seconds()),
+	})
+}
+
+// forgotPasswordAPI requests a reset link for an account of the type.
+func forgotPasswordAPI(c *gin.Context, kind service.PasswordAccountType) {
+	var req struct {
+		Login string `json:"login"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || req.Login == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "login is required"})
+		return
+	}
+	if remaining, limited := passwordResetRateLimited(c, "forgot-password"); limited {
+		writePasswordResetRateLimited(c, remaining)
+		return
+	}
+	passwordResetLimiter.RecordFailure(c.ClientIP(), "forgot-password")
+	svc := passwordResetService(c)
+	if svc == nil {
+		return
+	}
+	if err := svc.Request(c.Request.Context(), kind, req.Login, c.ClientIP()); err != nil {
+		passwordResetError(c, err, "request password reset")
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"success": true, "message": passwordResetSent})
+}
+
+// resetPasswordAPI sets a new password with a reset link token. Invalid
+// tokens count against the client IP.
+func resetPasswordAPI(c *gin.Context, kind service.PasswordAccountType) {
+	var req struct {
+		Token    string `json:"token"`
+		Password string `json:"password"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || req.Token == "" || req.Password == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "token and password are required"})
+		return
+	}
+	if remaining, limited := passwordResetRateLimited(c, "reset-password"); limited {
+		writePasswordResetRateLimited(c, remaining)
+		return
+	}
+	svc := passwordResetService(c)
+	if svc == nil {
+		return
+	}
+	if err := svc.Reset(c.Request.Context(), kind, req.Token, req.Password); err != nil {
+		if errors.Is(err, service.ErrPasswordResetLinkInvalid) {
+			passwordResetLimiter.RecordFailure(c.ClientIP(), "reset-password")
+		}
+		passwordResetError(c, err, "reset password")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Password changed"})
+}
+
+// HandleForgotPasswordAPI handles POST /api/auth/forgot-password.
+//
+//	@Summary		Request agent password reset
+//	@Description	Emails a single-use password reset link to the agent with the login. The answer is the same whether or not the account exists; too many requests for one login lock it out of resets for a while.
+//	@Tags			Password Reset
+//	@Accept			json
+//	@Produce		json
+//	@Param			request	body		object	true	"login"
+//	@Success		202		{object}	map[string]interface{}	"Reset link sent"
+//	@Failure		404		{object}	map[string]interface{}	"Password reset disabled"
+//	@Failure		429		{object}	map[string]interface{}	"Too many requests"
+//	@Router			/auth/forgot-password [post]
+func HandleForgotPasswordAPI(c *gin.Context) {
+	forgotPasswordAPI(c, service.PasswordAccountAgent)
+}
+
+// HandleResetPasswordAPI handles POST /api/auth/reset-password.
+//
+//	@Summary		Reset agent password
+//	@Description	Sets a new agent password with the token from the emailed link. The link works once.
+//	@Tags			Password Reset
+//	@Accept			json
+//	@Produce		json
+//	@Param			reset	body		object	true	"token, password"
+//	@Success		200		{object}	map[string]interface{}	"Password changed"
+//	@Failure		400		{object}	map[string]interface{}	"Password does not meet the policy"
+//	@Failure		404		{object}	map[string]interface{}	"Invalid link"
+//	@Failure		410		{object}	map[string]interface{}	"Expired link"
+//	@Router			/auth/reset-password [post]
+func HandleResetPasswordAPI(c *gin.Context) {
+	resetPasswordAPI(c, service.PasswordAccountAgent)
+}
+
+// HandleCustomerForgotPasswordAPI handles POST /api/auth/customer/forgot-password.
+//
+//	@Summary		Request customer password reset
+//	@Description	Emails a single-use password reset link to the customer with the login or email address. The answer is the same whether or not the account exists; too many requests for one account lock it out of resets for a while.
+//	@Tags			Password Reset
+//	@Accept			json
+//	@Produce		json
+//	@Param			request	body		object	true	"login"
+//	@Success		202		{object}	map[string]interface{}	"Reset link sent"
+//	@Failure		404		{object}	map[string]interface{}	"Password reset disabled"
+//	@Failure		429		{object}	map[string]interface{}	"Too many requests"
+//	@Router			/auth/customer/forgot-password [post]
+func HandleCustomerForgotPasswordAPI(c *gin.Context) {
+	forgotPasswordAPI(c, service.PasswordAccountCustomer)
+}
+
+// HandleCustomerResetPasswordAPI handles POST /api/auth/customer/reset-password.
+//
+//	@Summary		Reset customer password
+//	@Description	Sets a new customer password with the token from the emailed link. The link works once.
+//	@Tags			Password Reset
+//	@Accept			json
+//	@Produce		json
+//	@Param			reset	body		object	true	"token, password"
+//	@Success		200		{object}	map[string]interface{}	"Password changed"
+//	@Failure		400		{object}	map[string]interface{}	"Password does not meet the policy"
+//	@Failure		404		{object}	map[string]interface{}	"Invalid link"
+//	@Failure		410		{object}	map[string]interface{}	"Expired link"
+//	@Router			/auth/customer/reset-password [post]
+func HandleCustomerResetPasswordAPI(c *gin.Context) {
+	resetPasswordAPI(c, service.PasswordAccountCustomer)
+}
+
+// HandleGetPasswordResetSettingsAPI handles GET /api/v1/admin/password-reset/settings.
+//
+//	@Summary		Get password reset settings
+//	@Description	Returns who may reset their password, how long links stay valid and the request limit and lockout.
+//	@Tags			Password Reset
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}	"Password reset settings"
+//	@Security		BearerAuth
+//	@Router			/admin/password-reset/settings [get]
+func HandleGetPasswordResetSettingsAPI(c *gin.Context) {
+	svc := passwordResetService(c)
+	if svc == nil {
+		return
+	}
+	cfg, err := svc.Settings()
+	if err != nil {
+		passwordResetError(c, err, "load password reset settings")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": cfg})
+}
+
+// HandleUpdatePasswordResetSettingsAPI handles PUT /api/v1/admin/password-reset/settings.
+//
+//	@Summary		Update password reset settings
+//	@Description	Sets who may reset their password, the link lifetime, how many requests one account may make within the window and how long one more locks it out. Zero values take the defaults.
+//	@Tags			Password Reset
+//	@Accept			json
+//	@Produce		json
+//	@Param			settings	body		object	true	"Password reset settings"
+//	@Success		200			{object}	map[string]interface{}	"Password reset settings"
+//	@Failure		400			{object}	map[string]interface{}	"Invalid settings"
+//	@Security		BearerAuth
+//	@Router			/admin/password-reset/settings [put]
+func HandleUpdatePasswordResetSettingsAPI(c *gin.Context) {
+	var cfg sysconfig.PasswordResetConfig
+	if err := c.ShouldBindJSON(&cfg); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid request body"})
+		return
+	}
+	svc := passwordResetService(c)
+	if svc == nil {
+		return
+	}
+	saved, err := svc.SaveSettings(cfg, GetUserIDFromCtx(c, 1))
+	if err != nil {
+		passwordResetError(c, err, "save password reset settings")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": saved})
+}
+
+// HandleListPasswordResetLockoutsAPI handles GET /api/v1/admin/password-reset/lockouts.
+//
+//	@Summary		List password reset lockouts
+//	@Description	Lists the agent and customer logins currently locked out of password resets after too many requests.
+//	@Tags			Password Reset
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}	"Lockouts"
+//	@Security		BearerAuth
+//	@Router			/admin/password-reset/lockouts [get]
+func HandleListPasswordResetLockoutsAPI(c *gin.Context) {
+	svc := passwordResetService(c)
+	if svc == nil {
+		return
+	}
+	list, err := svc.Lockouts(c.Request.Context())
+	if err != nil {
+		passwordResetError(c, err, "load password reset lockouts")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": list})
+}
+
+// HandleDeletePasswordResetLockoutAPI handles DELETE /api/v1/admin/password-reset/lockouts/:type/:login.
+//
+//	@Summary		Lift password reset lockout
+//	@Description	Ends the password reset lockout of an agent or customer login before its time.
+//	@Tags			Password Reset
+//	@Produce		json
+//	@Param			type	path		string	true	"agent or customer"
+//	@Param			login	path		string	true	"Login"
+//	@Success		200		{object}	map[string]interface{}	"Lockout lifted"
+//	@Failure		404		{object}	map[string]interface{}	"Lockout not found"
+//	@Security		BearerAuth
+//	@Router			/admin/password-reset/lockouts/{type}/{login} [delete]
+func HandleDeletePasswordResetLockoutAPI(c *gin.Context) {
+	svc := passwordResetService(c)
+	if svc == nil {
+		return
+	}
+	if err := svc.Unlock(c.Request.Context(), service.PasswordAccountType(c.Param("type")), c.Param("login")); err != nil {
+		passwordResetError(c, err, "lift password reset lockout")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Lockout lifted"})
+}
@@ -0,0 +1,67 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/goatkit/goatflow/internal/service"
+)
+
+func TestPasswordResetHandlers_InvalidRequest(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.POST("/api/auth/forgot-password", HandleForgotPasswordAPI)
+	router.POST("/api/auth/customer/reset-password", HandleCustomerResetPasswordAPI)
+	router.PUT("/api/v1/admin/password-reset/settings", HandleUpdatePasswordResetSettingsAPI)
+
+	for _, tc := range []struct {
+		method string
+		path   string
+		body   string
+		want   string
+	}{
+		{http.MethodPost, "/api/auth/forgot-password", `{}`, "login is required"},
+		{http.MethodPost, "/api/auth/customer/reset-password", `{"token": "abc"}`, "token and password are required"},
+		{http.MethodPut, "/api/v1/admin/password-reset/settings", `{"max_requests": "3"}`, "Invalid request body"},
+	} {
+		t.Run(tc.method+" "+tc.path+" "+tc.body, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Contains(t, w.Body.String(), tc.want)
+		})
+	}
+}
+
+func TestPasswordResetMessage(t *testing.T) {
+	for _, tc := range []struct {
+		err    error
+		status int
+		known  bool
+	}{
+		{&service.PasswordResetPolicyError{Code: "min_size"}, http.StatusBadRequest, true},
+		{&service.PasswordResetLockedError{Until: time.Now().Add(time.Hour)}, http.StatusTooManyRequests, true},
+		{fmt.Errorf("%w: a login or email address is required", service.ErrPasswordResetInvalid), http.StatusBadRequest, true},
+		{service.ErrPasswordResetDisabled, http.StatusNotFound, true},
+		{service.ErrPasswordResetLinkInvalid, http.StatusNotFound, true},
+		{service.ErrPasswordResetLinkExpired, http.StatusGone, true},
+		{errors.New("connection refused"), http.StatusInternalServerError, false},
+	} {
+		status, msg, known := passwordResetMessage(tc.err)
+		assert.Equal(t, tc.status, status, tc.err.Error())
+		assert.Equal(t, tc.known, known, tc.err.Error())
+		assert.NotContains(t, msg, "connection refused")
+	}
+}
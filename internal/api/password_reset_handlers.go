@@ -0,0 +1,177 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/flosch/pongo2/v6"
+	"github.com/gin-gonic/gin"
+
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/service"
+)
+
+// renderPasswordReset renders the password reset page of the agent
+// interface or the customer portal in one of its states: request, sent,
+// form, done, disabled or invalid.
+func renderPasswordReset(c *gin.Context, kind service.PasswordAccountType, status int, state string, ctx pongo2.Context) {
+	if ctx == nil {
+		ctx = pongo2.Context{}
+	}
+	ctx["State"] = state
+	ctx["BasePath"] = ""
+	if kind == service.PasswordAccountCustomer {
+		ctx["BasePath"] = "/customer"
+		if db, err := database.GetDB(); err == nil && db != nil {
+			ctx = withPortalContext(ctx, customerPortalConfigFromContext(c, db))
+		}
+	}
+	getPongo2Renderer().HTML(c, status, "pages/password_reset.pongo2", ctx)
+}
+
+// passwordResetPageService returns the service, rendering the page as
+// unavailable when accounts of the type cannot reset their password.
+func passwordResetPageService(c *gin.Context, kind service.PasswordAccountType) (*service.PasswordResetService, bool) {
+	db, err := database.GetDB()
+	if err != nil || db == nil {
+		c.String(http.StatusServiceUnavailable, "Service unavailable")
+		return nil, false
+	}
+	svc := service.NewPasswordResetService(db)
+	enabled, err := svc.Enabled(kind)
+	if err != nil {
+		log.Printf("password reset: loading settings failed: %v", err)
+	}
+	if !enabled {
+		renderPasswordReset(c, kind, http.StatusNotFound, "disabled", nil)
+		return nil, false
+	}
+	return svc, true
+}
+
+func forgotPasswordPage(c *gin.Context, kind service.PasswordAccountType) {
+	if _, ok := passwordResetPageService(c, kind); !ok {
+		return
+	}
+	renderPasswordReset(c, kind, http.StatusOK, "request", nil)
+}
+
+func forgotPasswordSubmit(c *gin.Context, kind service.PasswordAccountType) {
+	svc, ok := passwordResetPageService(c, kind)
+	if !ok {
+		return
+	}
+	login := c.PostForm("login")
+	form := pongo2.Context{"Login": login}
+	if remaining, limited := passwordResetRateLimited(c, "forgot-password"); limited {
+		form["error"] = fmt.Sprintf("Too many requests. Please try again in %d minutes.", int(remaining.Minutes())+1)
+		renderPasswordReset(c, kind, http.StatusTooManyRequests, "request", form)
+		return
+	}
+	passwordResetLimiter.RecordFailure(c.ClientIP(), "forgot-password")
+	if err := svc.Request(c.Request.Context(), kind, login, c.ClientIP()); err != nil {
+		status, msg, known := passwordResetMessage(err)
+		if !known {
+			log.Printf("password reset: request failed: %v", err)
+		}
+		form["error"] = msg
+		renderPasswordReset(c, kind, status, "request", form)
+		return
+	}
+	renderPasswordReset(c, kind, http.StatusOK, "sent", nil)
+}
+
+func resetPasswordPage(c *gin.Context, kind service.PasswordAccountType) {
+	svc, ok := passwordResetPageService(c, kind)
+	if !ok {
+		return
+	}
+	if err := svc.Check(c.Request.Context(), kind, c.Param("token")); err != nil {
+		status, msg, known := passwordResetMessage(err)
+		if !known {
+			log.Printf("password reset: checking link failed: %v", err)
+		}
+		renderPasswordReset(c, kind, status, "invalid", pongo2.Context{"error": msg})
+		return
+	}
+	renderPasswordReset(c, kind, http.StatusOK, "form", nil)
+}
+
+func resetPasswordSubmit(c *gin.Context, kind service.PasswordAccountType) {
+	svc, ok := passwordResetPageService(c, kind)
+	if !ok {
+		return
+	}
+	password := c.PostForm("password")
+	if password != c.PostForm("password_confirm") {
+		renderPasswordReset(c, kind, http.StatusBadRequest, "form", pongo2.Context{"error": "The passwords do not match"})
+		return
+	}
+	if remaining, limited := passwordResetRateLimited(c, "reset-password"); limited {
+		renderPasswordReset(c, kind, http.StatusTooManyRequests, "invalid", pongo2.Context{
+			"error": fmt.Sprintf("Too many attempts. Please try again in %d minutes.", int(remaining.Minutes())+1),
+		})
+		return
+	}
+	if err := svc.Reset(c.Request.Context(), kind, c.Param("token"), password); err != nil {
+		status, msg, known := passwordResetMessage(err)
+		if !known {
+			log.Printf("password reset: reset failed: %v", err)
+		}
+		state := "form"
+		var pwErr *service.PasswordResetPolicyError
+		if !errors.As(err, &pwErr) && !errors.Is(err, service.ErrPasswordResetInvalid) {
+			state = "invalid"
+		}
+		if errors.Is(err, service.ErrPasswordResetLinkInvalid) {
+			passwordResetLimiter.RecordFailure(c.ClientIP(), "reset-password")
+		}
+		renderPasswordReset(c, kind, status, state, pongo2.Context{"error": msg})
+		return
+	}
+	renderPasswordReset(c, kind, http.StatusOK, "done", nil)
+}
+
+// handleForgotPasswordPage shows the agent forgot-password form.
+func handleForgotPasswordPage(c *gin.Context) {
+	forgotPasswordPage(c, service.PasswordAccountAgent)
+}
+
+// handleForgotPasswordSubmit emails an agent a reset link.
+func handleForgotPasswordSubmit(c *gin.Context) {
+	forgotPasswordSubmit(c, service.PasswordAccountAgent)
+}
+
+// handleResetPasswordPage shows the new password form of an agent reset
+// link; the link is only used up when the form is submitted.
+func handleResetPasswordPage(c *gin.Context) {
+	resetPasswordPage(c, service.PasswordAccountAgent)
+}
+
+// handleResetPasswordSubmit sets an agent's new password.
+func handleResetPasswordSubmit(c *gin.Context) {
+	resetPasswordSubmit(c, service.PasswordAccountAgent)
+}
+
+// handleCustomerForgotPasswordPage shows the customer forgot-password form.
+func handleCustomerForgotPasswordPage(c *gin.Context) {
+	forgotPasswordPage(c, service.PasswordAccountCustomer)
+}
+
+// handleCustomerForgotPasswordSubmit emails a customer a reset link.
+func handleCustomerForgotPasswordSubmit(c *gin.Context) {
+	forgotPasswordSubmit(c, service.PasswordAccountCustomer)
+}
+
+// handleCustomerResetPasswordPage shows the new password form of a
+// customer reset link.
+func handleCustomerResetPasswordPage(c *gin.Context) {
+	resetPasswordPage(c, service.PasswordAccountCustomer)
+}
+
+// handleCustomerResetPasswordSubmit sets a customer's new password.
+func handleCustomerResetPasswordSubmit(c *gin.Context) {
+	resetPasswordSubmit(c, service.PasswordAccountCustomer)
+}
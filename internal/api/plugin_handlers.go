@@ -6,9 +6,11 @@ import (
 	"errors"
 	"io"
 	"log"
+	"math"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 
 	"github.com/gin-gonic/gin"
@@ -16,6 +18,7 @@ import (
 	"github.com/goatkit/goatflow/internal/middleware"
 	"github.com/goatkit/goatflow/internal/plugin"
 	"github.com/goatkit/goatflow/internal/plugin/packaging"
+	"github.com/goatkit/goatflow/internal/routing"
 )
 
 // pluginContextWithLanguage adds the request language to the context for i18n support.
@@ -70,6 +73,8 @@ func HandlePluginList(c *gin.Context) {
 			"menuItems":   m.MenuItems,
 			"enabled":     pluginManager.IsEnabled(m.Name),
 			"loaded":      true,
+			"breaker":     pluginManager.BreakerStatus(m.Name),
+			"stats":       pluginManager.Stats(m.Name),
 		})
 	}
 
@@ -121,6 +126,15 @@ func HandlePluginCall(c *gin.Context) {
 			c.JSON(http.StatusForbidden, gin.H{"error": err.Error()})
 			return
 		}
+		if writePluginUnavailable(c, err) {
+			return
+		}
+		// Return 500 for traps and panics, the report is in /crashes
+		var crashErr *plugin.CrashError
+		if errors.As(err, &crashErr) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
@@ -129,6 +143,25 @@ func HandlePluginCall(c *gin.Context) {
 	c.Data(http.StatusOK, "application/json", result)
 }
 
+// writePluginUnavailable answers when err says the plugin could not serve
+// the call: 503 with a Retry-After header while its circuit breaker is
+// open, 504 when the call timed out. It reports whether it answered.
+func writePluginUnavailable(c *gin.Context, err error) bool {
+	var unavailable *plugin.PluginUnavailableError
+	if errors.As(err, &unavailable) {
+		retry := int(math.Ceil(unavailable.RetryAfter.Seconds()))
+		c.Header("Retry-After", strconv.Itoa(max(retry, 1)))
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return true
+	}
+	var timeout *plugin.PluginTimeoutError
+	if errors.As(err, &timeout) {
+		c.JSON(http.StatusGatewayTimeout, gin.H{"error": err.Error(), "timeout_ms": timeout.Timeout.Milliseconds()})
+		return true
+	}
+	return false
+}
+
 // HandlePluginEnable enables a plugin.
 // POST /api/v1/plugins/:name/enable
 func HandlePluginEnable(c *gin.Context) {
@@ -173,6 +206,75 @@ func HandlePluginDisable(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"status": "disabled"})
 }
 
+// HandlePluginUninstall unregisters a plugin. With ?drop_data=true its schema
+// namespace is dropped; otherwise the plugin's tables are retained.
+// DELETE /api/v1/plugins/:name
+func HandlePluginUninstall(c *gin.Context) {
+	if pluginManager == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Plugin system not initialized"})
+		return
+	}
+
+	name := c.Param("name")
+	dropData := c.Query("drop_data") == "true" || c.Query("drop_data") == "1"
+	if err := pluginManager.Uninstall(c.Request.Context(), name, dropData); err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	log.Printf("🔌 Plugin uninstalled: %s (drop_data=%t)", name, dropData)
+	c.JSON(http.StatusOK, gin.H{"status": "uninstalled", "data_dropped": dropData})
+}
+
+// HandlePluginSchema returns the migration state of a plugin's schema namespace.
+// GET /api/v1/plugins/:name/schema
+func HandlePluginSchema(c *gin.Context) {
+	if pluginManager == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Plugin system not initialized"})
+		return
+	}
+
+	status, err := pluginManager.SchemaStatus(c.Request.Context(), c.Param("name"))
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+// HandlePluginCrashes returns the latest crash reports of a plugin, newest first.
+// GET /api/v1/plugins/:name/crashes
+func HandlePluginCrashes(c *gin.Context) {
+	if pluginManager == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Plugin system not initialized"})
+		return
+	}
+
+	reports := pluginManager.CrashReports(c.Param("name"))
+	c.JSON(http.StatusOK, gin.H{"crashes": reports, "count": len(reports)})
+}
+
+// HandleClearPluginCrashes forgets the crash reports of a plugin.
+// DELETE /api/v1/plugins/:name/crashes
+func HandleClearPluginCrashes(c *gin.Context) {
+	if pluginManager == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Plugin system not initialized"})
+		return
+	}
+
+	pluginManager.ClearCrashReports(c.Param("name"))
+	c.JSON(http.StatusOK, gin.H{"status": "cleared"})
+}
+
 // HandlePluginWidgetList returns available widgets for a location (triggers lazy load).
 // GET /api/v1/plugins/widgets?location=dashboard
 func HandlePluginWidgetList(c *gin.Context) {
@@ -203,7 +305,7 @@ func HandlePluginWidgetList(c *gin.Context) {
 			PluginName:  w.PluginName,
 			Size:        w.Size,
 			Refreshable: w.Refreshable,
-			RefreshSec:  w.RefreshSec,
+			RefreshSec:  int(w.RefreshInterval().Seconds()),
 		})
 	}
 
@@ -223,53 +325,41 @@ func HandlePluginWidget(c *gin.Context) {
 	widgetID := c.Param("id")
 
 	// Get plugin (triggers lazy load via Call if needed)
-	p, ok := pluginManager.Get(pluginName)
-	if !ok {
+	if _, ok := pluginManager.Get(pluginName); !ok {
 		c.String(http.StatusNotFound, "Plugin not found: %s", pluginName)
 		return
 	}
 
 	// Get manifest and find the widget spec
-	manifest := p.GKRegister()
-	var widgetHandler string
-	var widgetTitle string
-	for _, w := range manifest.Widgets {
-		if w.ID == widgetID {
-			widgetHandler = w.Handler
-			widgetTitle = w.Title
-			break
-		}
-	}
-	if widgetHandler == "" {
+	spec, ok := findPluginWidget(pluginName, widgetID)
+	if !ok {
 		c.String(http.StatusNotFound, "Widget not found: %s/%s", pluginName, widgetID)
 		return
 	}
+	widgetTitle := spec.Title
 
-	// Call the widget handler (pass empty JSON object, not nil)
-	ctx := pluginContextWithLanguage(c)
-	result, err := pluginManager.Call(ctx, pluginName, widgetHandler, []byte("{}"))
-	if err != nil {
-		c.String(http.StatusInternalServerError, "Widget error: %v", err)
-		return
-	}
-
-	var data struct {
-		HTML string `json:"html"`
-	}
-	if err := json.Unmarshal(result, &data); err != nil {
-		c.String(http.StatusInternalServerError, "Invalid widget response")
-		return
+	// Isolated widgets never have their HTML inlined into the caller's page
+	var html string
+	if pluginManager.WidgetIsolation(pluginName) == plugin.WidgetIsolationIframe {
+		html = renderPluginWidgetIframe(pluginName, widgetID, widgetTitle)
+	} else {
+		var err error
+		html, err = pluginManager.RenderWidget(pluginContextWithLanguage(c), pluginName, spec)
+		if err != nil {
+			c.String(http.StatusInternalServerError, "Widget error: %v", err)
+			return
+		}
 	}
 
 	// Return HTML with optional wrapper for HTMX
 	if c.Query("wrap") == "true" {
 		c.Header("Content-Type", "text/html; charset=utf-8")
-		c.String(http.StatusOK, `<div class="gk-card-header"><h3 class="gk-card-title">%s</h3></div><div class="gk-card-body">%s</div>`, widgetTitle, data.HTML)
+		c.String(http.StatusOK, `<div class="gk-card-header"><h3 class="gk-card-title">%s</h3></div><div class="gk-card-body">%s</div>`, widgetTitle, html)
 		return
 	}
 
 	c.Header("Content-Type", "text/html; charset=utf-8")
-	c.String(http.StatusOK, data.HTML)
+	c.String(http.StatusOK, html)
 }
 
 // GetPluginWidgets returns rendered widgets for a dashboard location.
@@ -287,30 +377,33 @@ func GetPluginWidgets(ctx context.Context, location string) []PluginWidgetData {
 	results := make([]PluginWidgetData, 0, len(widgets))
 
 	for _, w := range widgets {
-		// Call the widget handler to get HTML (ctx should already have language if from gin)
-		result, err := pluginManager.Call(ctx, w.PluginName, w.Handler, nil)
-		if err != nil {
-			log.Printf("🔌 Widget %s:%s call failed: %v", w.PluginName, w.Handler, err)
-			continue
-		}
-
-		var data struct {
-			HTML string `json:"html"`
-		}
-		if err := json.Unmarshal(result, &data); err != nil {
-			log.Printf("🔌 Widget %s:%s unmarshal failed: %v (raw: %s)", w.PluginName, w.Handler, err, string(result))
-			continue
-		}
-
-		results = append(results, PluginWidgetData{
+		data := PluginWidgetData{
 			ID:          w.ID,
 			Title:       w.Title,
 			PluginName:  w.PluginName,
-			HTML:        data.HTML,
 			Size:        w.Size,
 			Refreshable: w.Refreshable,
-			RefreshSec:  w.RefreshSec,
-		})
+			RefreshSec:  int(w.RefreshInterval().Seconds()),
+		}
+		if w.Refreshable {
+			data.PollURL = pluginWidgetPollPath(w.PluginName, w.ID)
+		}
+
+		// Isolated widgets are fetched by their iframe, not rendered here
+		if w.Isolation == plugin.WidgetIsolationIframe {
+			data.FrameURL = pluginWidgetFramePath(w.PluginName, w.ID)
+			results = append(results, data)
+			continue
+		}
+
+		// Call the widget handler to get HTML (ctx should already have language if from gin)
+		html, err := pluginManager.RenderWidget(ctx, w.PluginName, w.WidgetSpec)
+		if err != nil {
+			log.Printf("🔌 Widget %s:%s render failed: %v", w.PluginName, w.Handler, err)
+			continue
+		}
+		data.HTML = html
+		results = append(results, data)
 	}
 
 	return results
@@ -324,7 +417,9 @@ type PluginWidgetData struct {
 	HTML        string
 	Size        string
 	Refreshable bool
-	RefreshSec  int
+	RefreshSec  int    // Polling interval, clamped by the host
+	PollURL     string // Set for refreshable widgets; polled every RefreshSec
+	FrameURL    string // Set instead of HTML for widgets rendered in a sandboxed iframe
 }
 
 // GetPluginMenuItems returns menu items for a location.
@@ -351,18 +446,68 @@ func RegisterPluginRoutes(r *gin.Engine) int {
 		handlerName := route.RouteSpec.Handler
 		middlewares := route.RouteSpec.Middleware
 
+		if err := route.RouteSpec.ValidateAccess(); err != nil {
+			log.Printf("⚠️ Skipping plugin route from %s: %v", pluginName, err)
+			continue
+		}
+		if err := registerPluginRouteScope(pluginName, route.RouteSpec); err != nil {
+			log.Printf("⚠️ Skipping plugin route from %s: %v", pluginName, err)
+			continue
+		}
+
 		// Build middleware chain based on manifest
 		var mwChain []gin.HandlerFunc
+		authenticated, admin := false, false
 		for _, mw := range middlewares {
 			switch mw {
 			case "auth":
-				mwChain = append(mwChain, JWTAuthMiddleware())
+				authenticated = true
 			case "admin":
-				mwChain = append(mwChain, JWTAuthMiddleware(), RequireAdmin())
+				authenticated, admin = true, true
 			// Add more middleware types as needed
 			}
 		}
 
+		// Routes require login unless they opt in to being public and the
+		// plugin's policy grants public routes. Roles, scopes and
+		// group/queue restrictions are enforced here rather than by the
+		// plugin.
+		spec := route.RouteSpec
+		var public *plugin.PublicRoutePolicy
+		if spec.IsPublic() {
+			if public = pluginManager.PublicRoutes(pluginName); public == nil {
+				log.Printf("⚠️ Skipping public plugin route %s %s from %s: its policy does not grant public routes",
+					spec.Method, spec.Path, pluginName)
+				continue
+			}
+			// Anonymous callers are always rate limited
+			limit := public.RouteRateLimit(spec)
+			spec.RateLimit = &limit
+			mwChain = append(mwChain, requirePublicRouteIP(pluginName, *public))
+		} else {
+			authenticated = true
+		}
+		if authenticated {
+			if spec.Scope != "" {
+				// API tokens are accepted once the route names their scope
+				mwChain = append(mwChain, middleware.UnifiedAuthMiddleware(getJWTManager()), middleware.RequireScope(spec.Scope))
+			} else {
+				mwChain = append(mwChain, JWTAuthMiddleware())
+			}
+		}
+		if admin {
+			mwChain = append(mwChain, RequireAdmin())
+		}
+		if len(spec.Roles) > 0 && !spec.IsPublic() {
+			mwChain = append(mwChain, requirePluginRouteRole(spec))
+		}
+		if spec.RequiresAccess() {
+			mwChain = append(mwChain, requirePluginRouteAccess(pluginName, spec))
+		}
+		if spec.RateLimit != nil {
+			mwChain = append(mwChain, pluginRouteRateLimit(pluginName, spec))
+		}
+
 		handler := func(c *gin.Context) {
 			// Build args from request
 			args := buildPluginArgs(c)
@@ -370,6 +515,9 @@ func RegisterPluginRoutes(r *gin.Engine) int {
 			// Use context with language for i18n support
 			ctx := pluginContextWithLanguage(c)
 			result, err := pluginManager.Call(ctx, pluginName, handlerName, args)
+			if writePluginUnavailable(c, err) {
+				return
+			}
 			if err != nil {
 				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 				return
@@ -392,7 +540,8 @@ func RegisterPluginRoutes(r *gin.Engine) int {
 		// Register with appropriate HTTP method, including middleware
 		path := route.RouteSpec.Path
 		handlers := append(mwChain, handler)
-		switch route.RouteSpec.Method {
+		method := route.RouteSpec.Method
+		switch method {
 		case "GET":
 			r.GET(path, handlers...)
 		case "POST":
@@ -404,8 +553,10 @@ func RegisterPluginRoutes(r *gin.Engine) int {
 		case "PATCH":
 			r.PATCH(path, handlers...)
 		default:
+			method = "GET"
 			r.GET(path, handlers...) // Default to GET
 		}
+		routing.RecordRoute(pluginSitemapEntry(method, pluginName, spec))
 
 		log.Printf("🔌 Registered plugin route: %s %s -> %s.%s",
 			route.RouteSpec.Method, path, pluginName, handlerName)
@@ -415,6 +566,44 @@ func RegisterPluginRoutes(r *gin.Engine) int {
 	return registered
 }
 
+// pluginSitemapEntry describes a mounted plugin route for the route sitemap.
+func pluginSitemapEntry(method, pluginName string, spec plugin.RouteSpec) routing.RouteEntry {
+	e := routing.RouteEntry{
+		Method:      method,
+		Path:        spec.Path,
+		Source:      routing.RouteSourcePlugin,
+		Module:      pluginName,
+		Handler:     spec.Handler,
+		Description: spec.Description,
+		Middleware:  spec.Middleware,
+	}
+	// Only auth and admin are mounted for plugins; others are ignored.
+	known := func(name string) bool { return name == "auth" || name == "admin" }
+	routing.DescribeMiddleware(&e, spec.Middleware, known)
+	if e.Role == "admin" {
+		e.Auth = routing.RouteAuthSession
+	}
+	if !spec.IsPublic() {
+		e.Auth = routing.RouteAuthSession
+		if len(spec.Roles) == 1 && e.Role == "" {
+			e.Role = spec.Roles[0]
+		}
+	}
+	if spec.Scope != "" {
+		e.Auth = routing.RouteAuthSessionOrToken
+		e.Scopes = append(e.Scopes, spec.Scope)
+	}
+	if spec.RequiresAccess() {
+		if e.Auth != routing.RouteAuthSessionOrToken {
+			e.Auth = routing.RouteAuthSession
+		}
+		e.Groups = append(e.Groups, spec.Groups...)
+		e.Queues = spec.Queues
+		e.Permission = spec.AccessPermission()
+	}
+	return e
+}
+
 // buildPluginArgs extracts request data into JSON args for the plugin.
 func buildPluginArgs(c *gin.Context) json.RawMessage {
 	args := make(map[string]any)
@@ -455,8 +644,15 @@ func buildPluginArgs(c *gin.Context) json.RawMessage {
 // GET  /api/v1/plugins                    - List all plugins (authenticated)
 // POST /api/v1/plugins/:name/call/:fn     - Call a plugin function (authenticated)
 // GET  /api/v1/plugins/:name/widgets/:id  - Get widget HTML (authenticated, HTMX-friendly)
+// GET  /api/v1/plugins/:name/widgets/:id/frame - Sandboxed widget document for iframe-isolated plugins (authenticated)
 // POST /api/v1/plugins/:name/enable       - Enable a plugin (admin only)
 // POST /api/v1/plugins/:name/disable      - Disable a plugin (admin only)
+// GET  /api/v1/plugins/:name/schema       - Plugin schema migration state (admin only)
+// DELETE /api/v1/plugins/:name            - Uninstall a plugin, ?drop_data=true drops its tables (admin only)
+// GET  /api/v1/plugins/:name/crashes      - Latest crash reports (traps, panics) of a plugin (admin only)
+// DELETE /api/v1/plugins/:name/crashes    - Clear a plugin's crash reports (admin only)
+// GET  /api/v1/plugins/:name/logs         - Stored log entries of a plugin, filtered by time and level (admin only)
+// GET  /api/v1/plugins/:name/logs/stream  - Live log entries of a plugin as Server-Sent Events (admin only)
 func RegisterPluginAPIRoutes(r *gin.RouterGroup) {
 	// Plugin list and call - require authentication
 	plugins := r.Group("/plugins")
@@ -466,6 +662,7 @@ func RegisterPluginAPIRoutes(r *gin.RouterGroup) {
 		plugins.POST("/:name/call/:fn", HandlePluginCall)
 		plugins.GET("/widgets", HandlePluginWidgetList)
 		plugins.GET("/:name/widgets/:id", HandlePluginWidget)
+		plugins.GET("/:name/widgets/:id/frame", HandlePluginWidgetFrame)
 	}
 
 	// Plugin management - require admin
@@ -474,6 +671,12 @@ func RegisterPluginAPIRoutes(r *gin.RouterGroup) {
 	{
 		pluginAdmin.POST("/:name/enable", HandlePluginEnable)
 		pluginAdmin.POST("/:name/disable", HandlePluginDisable)
+		pluginAdmin.GET("/:name/schema", HandlePluginSchema)
+		pluginAdmin.GET("/:name/crashes", HandlePluginCrashes)
+		pluginAdmin.DELETE("/:name/crashes", HandleClearPluginCrashes)
+		pluginAdmin.GET("/:name/logs", HandlePluginLogHistory)
+		pluginAdmin.GET("/:name/logs/stream", HandlePluginLogStream)
+		pluginAdmin.DELETE("/:name", HandlePluginUninstall)
 		pluginAdmin.POST("/upload", HandlePluginUpload)
 		pluginAdmin.GET("/logs", HandlePluginLogs)
 		pluginAdmin.DELETE("/logs", HandleClearPluginLogs)
@@ -5,6 +5,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"io"
 	"mime/multipart"
 	"net/http"
@@ -13,9 +14,12 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 
+	"github.com/goatkit/goatflow/internal/middleware"
+	"github.com/goatkit/goatflow/internal/models"
 	"github.com/goatkit/goatflow/internal/plugin"
 	"github.com/goatkit/goatflow/internal/plugin/example"
 )
@@ -33,13 +37,23 @@ func (m *mockHostAPI) DBQuery(ctx context.Context, query string, args ...any) ([
 func (m *mockHostAPI) DBExec(ctx context.Context, query string, args ...any) (int64, error) {
 	return 0, nil
 }
+func (m *mockHostAPI) DBBegin(ctx context.Context, dbName string) (string, error) {
+	return "tx-1", nil
+}
+func (m *mockHostAPI) DBCommit(ctx context.Context, txID string) error   { return nil }
+func (m *mockHostAPI) DBRollback(ctx context.Context, txID string) error { return nil }
 func (m *mockHostAPI) CacheGet(ctx context.Context, key string) ([]byte, bool, error) {
 	return nil, false, nil
 }
 func (m *mockHostAPI) CacheSet(ctx context.Context, key string, value []byte, ttlSeconds int) error {
 	return nil
 }
-func (m *mockHostAPI) CacheDelete(ctx context.Context, key string) error { return nil }
+func (m *mockHostAPI) CacheDelete(ctx context.Context, key string) error              { return nil }
+func (m *mockHostAPI) FilePut(ctx context.Context, path string, content []byte) error { return nil }
+func (m *mockHostAPI) FileGet(ctx context.Context, path string) ([]byte, bool, error) {
+	return nil, false, nil
+}
+func (m *mockHostAPI) FileDelete(ctx context.Context, path string) error { return nil }
 func (m *mockHostAPI) HTTPRequest(ctx context.Context, method, url string, headers map[string]string, body []byte) (int, []byte, error) {
 	return 200, nil, nil
 }
@@ -48,6 +62,7 @@ func (m *mockHostAPI) SendEmail(ctx context.Context, to, subject, body string, h
 }
 func (m *mockHostAPI) Log(ctx context.Context, level, message string, fields map[string]any) {}
 func (m *mockHostAPI) ConfigGet(ctx context.Context, key string) (string, error)            { return "", nil }
+func (m *mockHostAPI) FeatureEnabled(ctx context.Context, flag string, userID int) (bool, error) { return false, nil }
 func (m *mockHostAPI) Translate(ctx context.Context, key string, args ...any) string        { return "" }
 func (m *mockHostAPI) CallPlugin(ctx context.Context, pluginName, function string, args json.RawMessage) (json.RawMessage, error) {
 	return nil, nil
@@ -265,6 +280,215 @@ func TestHandlePluginDisable(t *testing.T) {
 	mgr.Enable("hello")
 }
 
+func TestHandlePluginSchema(t *testing.T) {
+	r, _ := setupPluginTestRouter()
+
+	req := httptest.NewRequest("GET", "/api/v1/plugins/hello/schema", nil)
+	addAuthHeader(req)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var status plugin.SchemaStatus
+	if err := json.Unmarshal(w.Body.Bytes(), &status); err != nil {
+		t.Fatalf("failed to parse schema response: %v", err)
+	}
+	if status.Namespace != "plg_hello_" || status.CurrentVersion != 0 {
+		t.Errorf("unexpected schema status %+v", status)
+	}
+
+	req = httptest.NewRequest("GET", "/api/v1/plugins/missing/schema", nil)
+	addAuthHeader(req)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for unknown plugin, got %d", w.Code)
+	}
+}
+
+// panickingPlugin panics on every call.
+type panickingPlugin struct{}
+
+func (p *panickingPlugin) GKRegister() plugin.GKRegistration {
+	return plugin.GKRegistration{Name: "panicky", Version: "1.0.0"}
+}
+
+func (p *panickingPlugin) Init(ctx context.Context, host plugin.HostAPI) error { return nil }
+
+func (p *panickingPlugin) Call(ctx context.Context, fn string, args json.RawMessage) (json.RawMessage, error) {
+	panic("nil pointer in " + fn)
+}
+
+func (p *panickingPlugin) Shutdown(ctx context.Context) error { return nil }
+
+func TestHandlePluginCrashes(t *testing.T) {
+	r, mgr := setupPluginTestRouter()
+	if err := mgr.Register(context.Background(), &panickingPlugin{}); err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest("POST", "/api/v1/plugins/panicky/call/report", strings.NewReader(`{"id":7}`))
+	req.Header.Set("Content-Type", "application/json")
+	addAuthHeader(req)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("expected 500 for a crashing plugin, got %d: %s", w.Code, w.Body.String())
+	}
+
+	req = httptest.NewRequest("GET", "/api/v1/plugins/panicky/crashes", nil)
+	addAuthHeader(req)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Crashes []plugin.CrashReport `json:"crashes"`
+		Count   int                  `json:"count"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to parse crashes response: %v", err)
+	}
+	if resp.Count != 1 || resp.Crashes[0].Function != "report" || resp.Crashes[0].Reason != "nil pointer in report" {
+		t.Fatalf("unexpected crashes %+v", resp)
+	}
+	if resp.Crashes[0].ArgsSize != len(`{"id":7}`) || resp.Crashes[0].Stack == "" {
+		t.Errorf("expected args size and stack in %+v", resp.Crashes[0])
+	}
+
+	req = httptest.NewRequest("DELETE", "/api/v1/plugins/panicky/crashes", nil)
+	addAuthHeader(req)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || len(mgr.CrashReports("panicky")) != 0 {
+		t.Errorf("expected crash reports cleared, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestHandlePluginCallBreakerOpen(t *testing.T) {
+	r, mgr := setupPluginTestRouter()
+	mgr.SetBreakerPolicy(plugin.BreakerPolicy{MinCalls: 2, OpenFor: time.Minute})
+	if err := mgr.Register(context.Background(), &panickingPlugin{}); err != nil {
+		t.Fatal(err)
+	}
+
+	call := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/v1/plugins/panicky/call/report", nil)
+		addAuthHeader(req)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	for i := 0; i < 2; i++ {
+		if w := call(); w.Code != http.StatusInternalServerError {
+			t.Fatalf("expected 500 before the breaker opens, got %d", w.Code)
+		}
+	}
+	w := call()
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503 with the breaker open, got %d: %s", w.Code, w.Body.String())
+	}
+	if got := w.Header().Get("Retry-After"); got != "60" {
+		t.Errorf("expected Retry-After 60, got %q", got)
+	}
+
+	req := httptest.NewRequest("GET", "/api/v1/plugins", nil)
+	addAuthHeader(req)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	var resp struct {
+		Plugins []struct {
+			Name    string               `json:"name"`
+			Breaker plugin.BreakerStatus `json:"breaker"`
+		} `json:"plugins"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to parse plugin list: %v", err)
+	}
+	states := map[string]plugin.BreakerState{}
+	for _, p := range resp.Plugins {
+		states[p.Name] = p.Breaker.State
+	}
+	if states["panicky"] != plugin.BreakerOpen || states["hello"] != plugin.BreakerClosed {
+		t.Errorf("unexpected breaker states %v", states)
+	}
+}
+
+// stallingPlugin blocks every call until its context is done.
+type stallingPlugin struct{}
+
+func (p *stallingPlugin) GKRegister() plugin.GKRegistration {
+	return plugin.GKRegistration{Name: "stalling", Version: "1.0.0"}
+}
+
+func (p *stallingPlugin) Init(ctx context.Context, host plugin.HostAPI) error { return nil }
+
+func (p *stallingPlugin) Call(ctx context.Context, fn string, args json.RawMessage) (json.RawMessage, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (p *stallingPlugin) Shutdown(ctx context.Context) error { return nil }
+
+func TestHandlePluginCallTimeout(t *testing.T) {
+	r, mgr := setupPluginTestRouter()
+	if err := mgr.Register(context.Background(), &stallingPlugin{}); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	req := httptest.NewRequest("POST", "/api/v1/plugins/stalling/call/report", nil).WithContext(ctx)
+	addAuthHeader(req)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusGatewayTimeout {
+		t.Fatalf("expected 504 for a timed out call, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Error     string `json:"error"`
+		TimeoutMs int64  `json:"timeout_ms"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+	if !strings.Contains(resp.Error, "timed out") || resp.TimeoutMs <= 0 || resp.TimeoutMs > 50 {
+		t.Errorf("unexpected timeout response %+v", resp)
+	}
+}
+
+func TestHandlePluginUninstall(t *testing.T) {
+	r, mgr := setupPluginTestRouter()
+
+	req := httptest.NewRequest("DELETE", "/api/v1/plugins/hello?drop_data=true", nil)
+	addAuthHeader(req)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), `"data_dropped":true`) {
+		t.Errorf("expected data_dropped in response, got %s", w.Body.String())
+	}
+	if _, ok := mgr.Get("hello"); ok {
+		t.Error("plugin should be unregistered after uninstall")
+	}
+
+	// Uninstalling again reports not found
+	req = httptest.NewRequest("DELETE", "/api/v1/plugins/hello", nil)
+	addAuthHeader(req)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected 404, got %d", w.Code)
+	}
+}
+
 func TestHandlePluginLogs(t *testing.T) {
 	r, _ := setupPluginTestRouter()
 
@@ -676,3 +900,291 @@ func TestHandlePluginUploadNoDir(t *testing.T) {
 		t.Errorf("expected 500 for missing dir, got %d", w.Code)
 	}
 }
+
+func TestPluginWidgetIframeIsolation(t *testing.T) {
+	r, _ := setupPluginTestRouter()
+
+	// Inline plugins are not served as frames
+	req := httptest.NewRequest("GET", "/api/v1/plugins/hello/widgets/hello-widget/frame", nil)
+	addAuthHeader(req)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for inline widget frame, got %d", w.Code)
+	}
+
+	host := plugin.NewProdHostAPI(plugin.WithPluginResourcePolicy("hello",
+		plugin.ResourcePolicy{WidgetIsolation: plugin.WidgetIsolationIframe}))
+	mgr := plugin.NewManager(host)
+	if err := mgr.Register(context.Background(), example.NewHelloPlugin()); err != nil {
+		t.Fatalf("register failed: %v", err)
+	}
+	SetPluginManager(mgr)
+
+	req = httptest.NewRequest("GET", "/api/v1/plugins/hello/widgets/hello-widget?wrap=true", nil)
+	addAuthHeader(req)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	body := w.Body.String()
+	if strings.Contains(body, "Hello from the plugin") {
+		t.Error("isolated widget HTML must not be inlined")
+	}
+	if !strings.Contains(body, `sandbox="allow-scripts"`) || !strings.Contains(body, `/api/v1/plugins/hello/widgets/hello-widget/frame`) {
+		t.Errorf("expected sandboxed iframe, got %s", body)
+	}
+
+	req = httptest.NewRequest("GET", "/api/v1/plugins/hello/widgets/hello-widget/frame", nil)
+	addAuthHeader(req)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if csp := w.Header().Get("Content-Security-Policy"); !strings.HasPrefix(csp, "sandbox allow-scripts;") {
+		t.Errorf("expected CSP sandbox, got %q", csp)
+	}
+	if !strings.Contains(w.Body.String(), "Hello from the plugin") || !strings.Contains(w.Body.String(), `"gk:widget-resize"`) {
+		t.Errorf("expected widget document with resize script, got %s", w.Body.String())
+	}
+
+	widgets := GetPluginWidgets(context.Background(), "dashboard")
+	if len(widgets) != 1 || widgets[0].HTML != "" || widgets[0].FrameURL == "" {
+		t.Errorf("expected frame URL instead of HTML, got %+v", widgets)
+	}
+}
+
+// restrictedRoutesPlugin is the hello plugin with group/queue-restricted routes.
+type restrictedRoutesPlugin struct {
+	*example.HelloPlugin
+}
+
+func (p restrictedRoutesPlugin) GKRegister() plugin.GKRegistration {
+	reg := p.HelloPlugin.GKRegister()
+	reg.Routes = []plugin.RouteSpec{
+		{Method: "GET", Path: "/plugins/restricted/support", Handler: "hello", Groups: []string{"support"}},
+		{Method: "GET", Path: "/plugins/restricted/junk", Handler: "hello", Middleware: []string{"auth"}, Queues: []string{"Junk"}, Permission: "rw"},
+		{Method: "GET", Path: "/plugins/restricted/invalid", Handler: "hello", Groups: []string{"support"}, Permission: "everything"},
+	}
+	return reg
+}
+
+func TestRegisterPluginRoutesEnforcesAccess(t *testing.T) {
+	mgr := plugin.NewManager(&mockHostAPI{})
+	if err := mgr.Register(context.Background(), restrictedRoutesPlugin{example.NewHelloPlugin()}); err != nil {
+		t.Fatalf("register failed: %v", err)
+	}
+	SetPluginManager(mgr)
+
+	var checked []plugin.RouteSpec
+	origCheck := pluginRouteAccessCheck
+	pluginRouteAccessCheck = func(ctx context.Context, userID uint, spec plugin.RouteSpec) (bool, error) {
+		checked = append(checked, spec)
+		return userID == 2 && len(spec.Groups) > 0 && spec.Groups[0] == "support", nil
+	}
+	defer func() { pluginRouteAccessCheck = origCheck }()
+
+	r := gin.New()
+	if n := RegisterPluginRoutes(r); n != 2 {
+		t.Fatalf("expected 2 routes (invalid permission skipped), got %d", n)
+	}
+
+	jwtManager := getJWTManager()
+	agentToken, _ := jwtManager.GenerateTokenWithAdmin(2, "agent@test.com", "Agent", false, 0)
+	adminToken, _ := jwtManager.GenerateTokenWithAdmin(1, "admin@test.com", "Admin", true, 0)
+
+	for _, tc := range []struct {
+		name  string
+		path  string
+		token string
+		want  int
+	}{
+		{"anonymous", "/plugins/restricted/support", "", http.StatusUnauthorized},
+		{"agent in group", "/plugins/restricted/support", agentToken, http.StatusOK},
+		{"agent without queue permission", "/plugins/restricted/junk", agentToken, http.StatusForbidden},
+		{"admin bypasses check", "/plugins/restricted/junk", adminToken, http.StatusOK},
+		{"invalid permission not mounted", "/plugins/restricted/invalid", adminToken, http.StatusNotFound},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tc.path, nil)
+			if tc.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tc.token)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tc.want {
+				t.Errorf("expected %d, got %d: %s", tc.want, w.Code, w.Body.String())
+			}
+		})
+	}
+
+	if len(checked) != 2 {
+		t.Fatalf("expected 2 access checks for agent requests, got %d", len(checked))
+	}
+	if checked[1].AccessPermission() != "rw" {
+		t.Errorf("expected rw permission for queue route, got %q", checked[1].AccessPermission())
+	}
+}
+
+// callerRoutesPlugin is the hello plugin with role, scope and rate limited routes.
+type callerRoutesPlugin struct {
+	*example.HelloPlugin
+}
+
+func (p callerRoutesPlugin) GKRegister() plugin.GKRegistration {
+	reg := p.HelloPlugin.GKRegister()
+	reg.Routes = []plugin.RouteSpec{
+		{Method: "GET", Path: "/plugins/callers/agents", Handler: "hello", Roles: []string{"agent"}},
+		{Method: "GET", Path: "/plugins/callers/portal", Handler: "hello", Roles: []string{"customer"}},
+		{Method: "GET", Path: "/plugins/callers/open", Handler: "hello", Roles: []string{"public"}, RateLimit: &plugin.RouteRateLimit{Requests: 2}},
+		{Method: "GET", Path: "/plugins/callers/reports", Handler: "hello", Roles: []string{"agent"}, Scope: "plugin:hello:reports"},
+		{Method: "GET", Path: "/plugins/callers/foreign", Handler: "hello", Scope: "plugin:other:read"},
+		{Method: "GET", Path: "/plugins/callers/confused", Handler: "hello", Roles: []string{"public"}, Middleware: []string{"auth"}},
+	}
+	return reg
+}
+
+// staticAPITokens verifies the API tokens of a test.
+type staticAPITokens map[string]*models.APIToken
+
+func (s staticAPITokens) VerifyToken(ctx context.Context, raw string) (*models.APIToken, error) {
+	if t, ok := s[raw]; ok {
+		return t, nil
+	}
+	return nil, errors.New("invalid token")
+}
+
+func (s staticAPITokens) UpdateLastUsed(ctx context.Context, tokenID int64, ip string) error { return nil }
+
+func TestRegisterPluginRoutesEnforcesCallers(t *testing.T) {
+	host := plugin.NewProdHostAPI(plugin.WithPluginResourcePolicy("hello",
+		plugin.ResourcePolicy{PublicRoutes: &plugin.PublicRoutePolicy{}}))
+	mgr := plugin.NewManager(host)
+	if err := mgr.Register(context.Background(), callerRoutesPlugin{example.NewHelloPlugin()}); err != nil {
+		t.Fatalf("register failed: %v", err)
+	}
+	SetPluginManager(mgr)
+	middleware.SetAPITokenVerifier(staticAPITokens{
+		"gf_reports": {ID: 1, UserID: 2, UserType: models.APITokenUserAgent, Prefix: "gf_reports", Scopes: []string{"plugin:hello:reports"}},
+		"gf_tickets": {ID: 2, UserID: 2, UserType: models.APITokenUserAgent, Prefix: "gf_tickets", Scopes: []string{"tickets:read"}},
+	})
+	defer middleware.SetAPITokenVerifier(nil)
+	defer models.UnregisterScope("plugin:hello:reports")
+
+	r := gin.New()
+	if n := RegisterPluginRoutes(r); n != 4 {
+		t.Fatalf("expected 4 routes (foreign scope and public route with auth skipped), got %d", n)
+	}
+	if !models.IsValidScope("plugin:hello:reports") {
+		t.Error("expected the plugin scope to be offered for API tokens")
+	}
+
+	jwtManager := getJWTManager()
+	agentToken, _ := jwtManager.GenerateTokenWithAdmin(2, "agent@test.com", "Agent", false, 0)
+	customerToken, _ := jwtManager.GenerateTokenWithAdmin(3, "customer@test.com", "Customer", false, 0)
+
+	for _, tc := range []struct {
+		name  string
+		path  string
+		token string
+		want  int
+	}{
+		{"anonymous agent route", "/plugins/callers/agents", "", http.StatusUnauthorized},
+		{"agent on agent route", "/plugins/callers/agents", agentToken, http.StatusOK},
+		{"customer on agent route", "/plugins/callers/agents", customerToken, http.StatusForbidden},
+		{"agent on customer route", "/plugins/callers/portal", agentToken, http.StatusForbidden},
+		{"customer on customer route", "/plugins/callers/portal", customerToken, http.StatusOK},
+		{"token with plugin scope", "/plugins/callers/reports", "gf_reports", http.StatusOK},
+		{"token without plugin scope", "/plugins/callers/reports", "gf_tickets", http.StatusForbidden},
+		{"agent session on scoped route", "/plugins/callers/reports", agentToken, http.StatusOK},
+		{"foreign scope not mounted", "/plugins/callers/foreign", agentToken, http.StatusNotFound},
+		{"public route with auth not mounted", "/plugins/callers/confused", agentToken, http.StatusNotFound},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tc.path, nil)
+			if tc.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tc.token)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tc.want {
+				t.Errorf("expected %d, got %d: %s", tc.want, w.Code, w.Body.String())
+			}
+		})
+	}
+
+	t.Run("rate limit", func(t *testing.T) {
+		for i, want := range []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests} {
+			req := httptest.NewRequest("GET", "/plugins/callers/open", nil)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != want {
+				t.Fatalf("request %d: expected %d, got %d", i+1, want, w.Code)
+			}
+			if want == http.StatusTooManyRequests && w.Header().Get("Retry-After") != "30" {
+				t.Errorf("expected Retry-After 30, got %q", w.Header().Get("Retry-After"))
+			}
+		}
+	})
+}
+
+// hookRoutesPlugin is the hello plugin with a webhook receiver.
+type hookRoutesPlugin struct {
+	*example.HelloPlugin
+}
+
+func (p hookRoutesPlugin) GKRegister() plugin.GKRegistration {
+	reg := p.HelloPlugin.GKRegister()
+	reg.Routes = []plugin.RouteSpec{
+		{Method: "POST", Path: "/plugins/hooks/receive", Handler: "hello", Public: true},
+		{Method: "GET", Path: "/plugins/hooks/status", Handler: "hello"},
+	}
+	return reg
+}
+
+func TestRegisterPluginRoutesPublicOptIn(t *testing.T) {
+	mount := func(host plugin.HostAPI) *gin.Engine {
+		mgr := plugin.NewManager(host)
+		if err := mgr.Register(context.Background(), hookRoutesPlugin{example.NewHelloPlugin()}); err != nil {
+			t.Fatalf("register failed: %v", err)
+		}
+		SetPluginManager(mgr)
+		r := gin.New()
+		RegisterPluginRoutes(r)
+		return r
+	}
+	serve := func(r *gin.Engine, method, path, remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// Without the capability the public route is not mounted and routes
+	// that declare nothing require login
+	r := mount(&mockHostAPI{})
+	if w := serve(r, "POST", "/plugins/hooks/receive", "192.0.2.10:4000"); w.Code != http.StatusNotFound {
+		t.Errorf("expected the public route not to be mounted, got %d", w.Code)
+	}
+	if w := serve(r, "GET", "/plugins/hooks/status", "192.0.2.10:4000"); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected login to be required, got %d", w.Code)
+	}
+
+	r = mount(plugin.NewProdHostAPI(plugin.WithPluginResourcePolicy("hello", plugin.ResourcePolicy{
+		PublicRoutes: &plugin.PublicRoutePolicy{AllowIPs: []string{"192.0.2.0/24"}, RateLimit: 2},
+	})))
+	for i, want := range []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests} {
+		if w := serve(r, "POST", "/plugins/hooks/receive", "192.0.2.10:4000"); w.Code != want {
+			t.Fatalf("request %d from an allowed address: expected %d, got %d: %s", i+1, want, w.Code, w.Body.String())
+		}
+	}
+	if w := serve(r, "POST", "/plugins/hooks/receive", "198.51.100.7:4000"); w.Code != http.StatusForbidden {
+		t.Errorf("expected 403 outside the allowlist, got %d", w.Code)
+	}
+	if w := serve(r, "GET", "/plugins/hooks/status", "192.0.2.10:4000"); w.Code != http.StatusUnauthorized {
+		t.Errorf("expected the capability to leave other routes behind login, got %d", w.Code)
+	}
+}
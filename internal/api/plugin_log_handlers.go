@@ -0,0 +1,133 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/plugin"
+	"github.com/goatkit/goatflow/internal/service"
+)
+
+// pluginLogStreamBuffer is how many entries a slow log stream client may
+// fall behind before entries are dropped for it.
+const pluginLogStreamBuffer = 256
+
+// HandlePluginLogHistory returns a plugin's stored log entries, newest first.
+// Without a database it answers from the in-memory buffer.
+// GET /api/v1/plugins/:name/logs?from=2026-10-16T08:00:00Z&to=...&level=warn&limit=100
+func HandlePluginLogHistory(c *gin.Context) {
+	q := models.PluginLogQuery{Plugin: c.Param("name"), Level: c.Query("level")}
+	var err error
+	if q.From, err = parsePluginLogTime(c.Query("from")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from: " + err.Error()})
+		return
+	}
+	if q.To, err = parsePluginLogTime(c.Query("to")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "to: " + err.Error()})
+		return
+	}
+	if limit := c.Query("limit"); limit != "" {
+		if q.Limit, err = strconv.Atoi(limit); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a number"})
+			return
+		}
+	}
+
+	source := "database"
+	var entries []models.PluginLogEntry
+	if db, dbErr := database.GetDB(); dbErr == nil && db != nil {
+		entries, err = service.NewPluginLogService(db).Query(c.Request.Context(), q)
+	} else {
+		source = "memory"
+		entries, err = service.FilterPluginLogs(plugin.GetLogBuffer().GetByPlugin(q.Plugin), q)
+	}
+	if errors.Is(err, service.ErrPluginLogQuery) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Printf("plugin logs: query for %s failed: %v", q.Plugin, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load plugin logs"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"logs":   entries,
+		"count":  len(entries),
+		"source": source,
+	})
+}
+
+// parsePluginLogTime parses an RFC 3339 time; empty leaves the range open.
+func parsePluginLogTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("must be an RFC 3339 time such as 2026-10-16T08:00:00Z")
+	}
+	return t, nil
+}
+
+// HandlePluginLogStream streams a plugin's log entries as Server-Sent Events
+// while they are written, so the admin page can tail them.
+// GET /api/v1/plugins/:name/logs/stream?level=warn
+func HandlePluginLogStream(c *gin.Context) {
+	name := c.Param("name")
+	levels, err := service.PluginLogLevelsFrom(c.Query("level"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	allowed := make(map[string]bool, len(levels))
+	for _, l := range levels {
+		allowed[l] = true
+	}
+
+	entries, unsubscribe := plugin.GetLogBuffer().Subscribe(pluginLogStreamBuffer)
+	defer unsubscribe()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	_, _ = fmt.Fprint(c.Writer, "event: connected\ndata: {}\n\n") //nolint:errcheck // Best effort streaming
+	c.Writer.Flush()
+
+	ticker := time.NewTicker(eventStreamHeartbeat)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case e, ok := <-entries:
+			if !ok {
+				return
+			}
+			if e.Plugin != name || (len(allowed) > 0 && !allowed[e.Level]) {
+				continue
+			}
+			data, err := json.Marshal(e)
+			if err != nil {
+				continue
+			}
+			_, _ = fmt.Fprintf(c.Writer, "event: log\ndata: %s\n\n", data) //nolint:errcheck // Best effort streaming
+			c.Writer.Flush()
+		case <-ticker.C:
+			_, _ = fmt.Fprint(c.Writer, "event: heartbeat\ndata: {}\n\n") //nolint:errcheck // Best effort streaming
+			c.Writer.Flush()
+		case <-c.Request.Context().Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/plugin"
+	"github.com/goatkit/goatflow/internal/service"
+	"github.com/goatkit/goatflow/internal/testutil"
+)
+
+func TestHandlePluginLogHistory(t *testing.T) {
+	db := testutil.UseMigratedDB(t)
+	r, _ := setupPluginTestRouter()
+
+	now := time.Now().UTC().Truncate(time.Second)
+	require.NoError(t, service.NewPluginLogService(db).Record(context.Background(), []models.PluginLogEntry{
+		{Timestamp: now.Add(-time.Minute), Plugin: "hello", Level: "error", Message: "recent failure"},
+		{Timestamp: now.Add(-2 * time.Hour), Plugin: "hello", Level: "info", Message: "old greeting"},
+		{Timestamp: now.Add(-time.Minute), Plugin: "stats", Level: "error", Message: "other plugin"},
+	}))
+
+	get := func(path string) (*httptest.ResponseRecorder, map[string]any) {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		addAuthHeader(req)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		var result map[string]any
+		_ = json.Unmarshal(w.Body.Bytes(), &result)
+		return w, result
+	}
+
+	w, result := get("/api/v1/plugins/hello/logs")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "database", result["source"])
+	assert.EqualValues(t, 2, result["count"])
+
+	from := now.Add(-time.Hour).Format(time.RFC3339)
+	w, result = get("/api/v1/plugins/hello/logs?from=" + from)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.EqualValues(t, 1, result["count"])
+	assert.Equal(t, "recent failure", result["logs"].([]any)[0].(map[string]any)["message"])
+
+	w, result = get("/api/v1/plugins/hello/logs?level=warn")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.EqualValues(t, 1, result["count"])
+
+	for _, bad := range []string{"?from=yesterday", "?limit=many", "?limit=5000", "?level=loud"} {
+		w, _ := get("/api/v1/plugins/hello/logs" + bad)
+		assert.Equal(t, http.StatusBadRequest, w.Code, bad)
+	}
+}
+
+func TestHandlePluginLogStream(t *testing.T) {
+	r, _ := setupPluginTestRouter()
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/api/v1/plugins/hello/logs/stream?level=warn", nil)
+	require.NoError(t, err)
+	addAuthHeader(req)
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	lines := bufio.NewScanner(resp.Body)
+	require.True(t, lines.Scan())
+	require.Equal(t, "event: connected", lines.Text())
+
+	buf := plugin.GetLogBuffer()
+	buf.Log("stats", "error", "other plugin", nil)
+	buf.Log("hello", "info", "below the level", nil)
+	buf.Log("hello", "error", "tailed", map[string]any{"attempt": 2})
+
+	var data string
+	for lines.Scan() {
+		if strings.HasPrefix(lines.Text(), "data: ") && lines.Text() != "data: {}" {
+			data = strings.TrimPrefix(lines.Text(), "data: ")
+			break
+		}
+	}
+	var entry models.PluginLogEntry
+	require.NoError(t, json.Unmarshal([]byte(data), &entry))
+	assert.Equal(t, "tailed", entry.Message, "entries of other plugins and lower levels are skipped")
+	assert.EqualValues(t, 2, entry.Fields["attempt"])
+}
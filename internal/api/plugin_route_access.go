@@ -0,0 +1,237 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/middleware"
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/plugin"
+	"github.com/goatkit/goatflow/internal/service"
+)
+
+// pluginRouteAccessCheck decides whether an agent may call a restricted
+// plugin route. Tests replace it to avoid a database.
+var pluginRouteAccessCheck = checkPluginRouteAccess
+
+// requirePluginRouteAccess enforces a route's group/queue restrictions before
+// the plugin is called, so plugins never see requests from agents outside
+// the configured groups. It expects JWTAuthMiddleware to have run.
+func requirePluginRouteAccess(pluginName string, spec plugin.RouteSpec) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, exists := c.Get("user_id"); !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+			c.Abort()
+			return
+		}
+		if kbIsCustomer(c) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Agent access required"})
+			c.Abort()
+			return
+		}
+		if isAdmin, _ := c.Get("isInAdminGroup"); isAdmin == true {
+			c.Next()
+			return
+		}
+
+		userID := GetUserIDFromCtxUint(c, 0)
+		allowed, err := pluginRouteAccessCheck(c.Request.Context(), userID, spec)
+		if err != nil {
+			log.Printf("plugin %s: access check for %s %s failed: %v", pluginName, spec.Method, spec.Path, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check permissions"})
+			c.Abort()
+			return
+		}
+		if !allowed {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You do not have permission to access this resource"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// requirePluginRouteRole rejects callers whose role the route does not
+// allow. Admins count as agents. It expects an auth middleware to have run.
+func requirePluginRouteRole(spec plugin.RouteSpec) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, exists := c.Get("user_id"); !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+			c.Abort()
+			return
+		}
+		if kbIsCustomer(c) {
+			if !spec.AllowsRole(plugin.RouteRoleCustomer) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "Agent access required"})
+				c.Abort()
+				return
+			}
+		} else if !spec.AllowsRole(plugin.RouteRoleAgent) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Customer access required"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// requirePublicRouteIP rejects clients outside the IP allowlist of a
+// plugin's public routes.
+func requirePublicRouteIP(pluginName string, policy plugin.PublicRoutePolicy) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !policy.AllowsIP(c.ClientIP()) {
+			log.Printf("plugin %s: public route %s refused for %s", pluginName, c.FullPath(), c.ClientIP())
+			c.JSON(http.StatusForbidden, gin.H{"error": "Access from this address is not allowed"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// registerPluginRouteScope checks the scope a route requires and offers the
+// plugin's own scopes for API tokens. A plugin may require core scopes but
+// only define scopes under "plugin:<name>:".
+func registerPluginRouteScope(pluginName string, spec plugin.RouteSpec) error {
+	if spec.Scope == "" {
+		return nil
+	}
+	prefix := "plugin:" + pluginName + ":"
+	if !strings.HasPrefix(spec.Scope, prefix) {
+		if spec.Scope == "*" || !models.IsValidScope(spec.Scope) {
+			return fmt.Errorf("route %s %s: unknown scope %q, plugin scopes start with %q", spec.Method, spec.Path, spec.Scope, prefix)
+		}
+		return nil
+	}
+	if len(spec.Scope) == len(prefix) || strings.HasSuffix(spec.Scope, "*") {
+		return fmt.Errorf("route %s %s: invalid scope %q", spec.Method, spec.Path, spec.Scope)
+	}
+	if !models.IsValidScope(spec.Scope) {
+		description := spec.Description
+		if description == "" {
+			description = spec.Method + " " + spec.Path
+		}
+		models.RegisterScope(&models.ScopeDefinition{
+			Scope:       spec.Scope,
+			Description: description,
+			Category:    "plugin:" + pluginName,
+			AgentOnly:   len(spec.Roles) > 0 && !spec.AllowsRole(plugin.RouteRoleCustomer),
+		})
+	}
+	return nil
+}
+
+// pluginRouteLimiter holds the buckets of rate-limited plugin routes.
+var pluginRouteLimiter = middleware.NewRateLimiter()
+
+// pluginRouteRateLimit answers 429 once a caller used up the route's rate
+// limit. Callers are told apart by API token, user or client IP.
+func pluginRouteRateLimit(pluginName string, spec plugin.RouteSpec) gin.HandlerFunc {
+	limit, window := spec.RateLimit.Requests, spec.RateLimit.Window()
+	retryAfter := strconv.Itoa(max(int(math.Ceil(window.Seconds()/float64(limit))), 1))
+	return func(c *gin.Context) {
+		caller := "ip:" + c.ClientIP()
+		if token, ok := c.Get("api_token"); ok {
+			if t, ok := token.(*models.APIToken); ok {
+				caller = "token:" + t.Prefix
+			}
+		} else if userID := GetUserIDFromCtxUint(c, 0); userID != 0 {
+			kind := "user"
+			if kbIsCustomer(c) {
+				kind = "customer" // Customer and agent IDs overlap
+			}
+			caller = fmt.Sprintf("%s:%d", kind, userID)
+		}
+		key := pluginName + ":" + spec.Method + " " + spec.Path + ":" + caller
+
+		c.Header("X-RateLimit-Limit", strconv.Itoa(limit))
+		if !pluginRouteLimiter.AllowWindow(key, limit, window) {
+			c.Header("X-RateLimit-Remaining", "0")
+			c.Header("Retry-After", retryAfter)
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Rate limit exceeded"})
+			c.Abort()
+			return
+		}
+		c.Header("X-RateLimit-Remaining", strconv.Itoa(pluginRouteLimiter.Remaining(key)))
+		c.Next()
+	}
+}
+
+// checkPluginRouteAccess resolves the route's groups and queues to group IDs
+// and checks them against the agent's effective permissions. Names that do
+// not resolve grant nothing.
+func checkPluginRouteAccess(ctx context.Context, userID uint, spec plugin.RouteSpec) (bool, error) {
+	if userID == 0 {
+		return false, nil
+	}
+	db, err := database.GetDB()
+	if err != nil || db == nil {
+		return false, fmt.Errorf("database unavailable: %w", err)
+	}
+
+	access := service.NewQueueAccessService(db)
+	isAdmin, err := access.IsAdmin(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	if isAdmin {
+		return true, nil
+	}
+
+	required := make(map[uint]bool)
+	for _, lookup := range []struct {
+		query string
+		names []string
+	}{
+		{"SELECT id FROM `groups` WHERE valid_id = 1 AND name IN (%s)", spec.Groups},
+		{"SELECT group_id FROM queue WHERE valid_id = 1 AND name IN (%s)", spec.Queues},
+	} {
+		if len(lookup.names) == 0 {
+			continue
+		}
+		placeholders := strings.TrimSuffix(strings.Repeat("?,", len(lookup.names)), ",")
+		args := make([]interface{}, len(lookup.names))
+		for i, name := range lookup.names {
+			args[i] = name
+		}
+		rows, err := db.QueryContext(ctx, database.ConvertPlaceholders(fmt.Sprintf(lookup.query, placeholders)), args...)
+		if err != nil {
+			return false, fmt.Errorf("resolve route groups: %w", err)
+		}
+		for rows.Next() {
+			var id uint
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return false, fmt.Errorf("scan route group: %w", err)
+			}
+			required[id] = true
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return false, err
+		}
+	}
+	if len(required) == 0 {
+		return false, nil
+	}
+
+	groupIDs, err := access.GetUserEffectiveGroupIDs(ctx, userID, spec.AccessPermission())
+	if err != nil {
+		return false, err
+	}
+	for _, id := range groupIDs {
+		if required[id] {
+			return true, nil
+		}
+	}
+	return false, nil
+}
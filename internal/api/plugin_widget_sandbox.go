@@ -0,0 +1,145 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/goatkit/goatflow/internal/plugin"
+)
+
+// pluginWidgetFrameCSP is sent with sandboxed widget documents. The sandbox
+// directive gives the document an opaque origin even when it is opened
+// directly, so widget scripts cannot read agent cookies, storage or the
+// dashboard DOM. Network access is limited to the app stylesheet and images.
+const pluginWidgetFrameCSP = "sandbox allow-scripts; default-src 'none'; " +
+	"script-src 'unsafe-inline'; style-src 'self' 'unsafe-inline'; img-src 'self' data: https:; " +
+	"font-src 'self'; connect-src 'none'; form-action 'none'; base-uri 'none'; frame-ancestors 'self'"
+
+// pluginWidgetResizeMessage is the postMessage type a sandboxed widget sends
+// to report its content height. The dashboard listener matches it against the
+// iframe's contentWindow, so the payload itself is not trusted.
+const pluginWidgetResizeMessage = "gk:widget-resize"
+
+// pluginWidgetIframe is the placeholder rendered in place of an isolated
+// widget's HTML. No plugin output reaches the parent DOM.
+var pluginWidgetIframe = template.Must(template.New("iframe").Parse(
+	`<iframe class="plugin-widget-frame w-full border-0" src="{{ .Src }}" title="{{ .Title }}" ` +
+		`sandbox="allow-scripts" referrerpolicy="no-referrer" loading="lazy" ` +
+		`data-plugin="{{ .Plugin }}" data-widget-id="{{ .Widget }}" style="height: 8rem;"></iframe>`))
+
+// pluginWidgetFrameDocument wraps widget HTML in a standalone document that
+// reports its height to the parent frame.
+var pluginWidgetFrameDocument = template.Must(template.New("frame").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<link rel="stylesheet" href="/static/css/output.css">
+<style>html,body{margin:0;padding:0;background:transparent;overflow:hidden}</style>
+</head>
+<body>
+{{ .HTML }}
+<script>
+(function () {
+    var last = 0;
+    function report() {
+        var height = Math.ceil(document.documentElement.scrollHeight);
+        if (height === last) return;
+        last = height;
+        parent.postMessage({ type: {{ .Message }}, plugin: {{ .Plugin }}, widget: {{ .Widget }}, height: height }, "*");
+    }
+    window.addEventListener("load", report);
+    if (window.ResizeObserver) {
+        new ResizeObserver(report).observe(document.documentElement);
+    } else {
+        setInterval(report, 1000);
+    }
+    report();
+})();
+</script>
+</body>
+</html>
+`))
+
+// pluginWidgetFramePath returns the URL serving a widget as a sandboxed document.
+func pluginWidgetFramePath(pluginName, widgetID string) string {
+	return fmt.Sprintf("/api/v1/plugins/%s/widgets/%s/frame", url.PathEscape(pluginName), url.PathEscape(widgetID))
+}
+
+// renderPluginWidgetIframe returns the iframe element for an isolated widget.
+func renderPluginWidgetIframe(pluginName, widgetID, title string) string {
+	var buf bytes.Buffer
+	_ = pluginWidgetIframe.Execute(&buf, map[string]string{
+		"Src":    pluginWidgetFramePath(pluginName, widgetID),
+		"Title":  title,
+		"Plugin": pluginName,
+		"Widget": widgetID,
+	})
+	return buf.String()
+}
+
+// findPluginWidget looks up a widget spec in a registered plugin's manifest.
+func findPluginWidget(pluginName, widgetID string) (plugin.WidgetSpec, bool) {
+	p, ok := pluginManager.Get(pluginName)
+	if !ok {
+		return plugin.WidgetSpec{}, false
+	}
+	for _, w := range p.GKRegister().Widgets {
+		if w.ID == widgetID {
+			return w, true
+		}
+	}
+	return plugin.WidgetSpec{}, false
+}
+
+// HandlePluginWidgetFrame serves a widget as a standalone sandboxed document
+// for iframe embedding. Only plugins whose policy selects iframe isolation
+// are served here; inline widgets use HandlePluginWidget.
+// GET /api/v1/plugins/:name/widgets/:id/frame
+func HandlePluginWidgetFrame(c *gin.Context) {
+	if pluginManager == nil {
+		c.String(http.StatusServiceUnavailable, "Plugin system not initialized")
+		return
+	}
+
+	pluginName := c.Param("name")
+	widgetID := c.Param("id")
+
+	spec, ok := findPluginWidget(pluginName, widgetID)
+	if !ok {
+		c.String(http.StatusNotFound, "Widget not found: %s/%s", pluginName, widgetID)
+		return
+	}
+	if pluginManager.WidgetIsolation(pluginName) != plugin.WidgetIsolationIframe {
+		c.String(http.StatusNotFound, "Widget is not isolated: %s/%s", pluginName, widgetID)
+		return
+	}
+
+	html, err := pluginManager.RenderWidget(pluginContextWithLanguage(c), pluginName, spec)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Widget error: %v", err)
+		return
+	}
+
+	var buf bytes.Buffer
+	err = pluginWidgetFrameDocument.Execute(&buf, map[string]any{
+		"HTML":    template.HTML(html), //nolint:gosec // G203 - only served under the CSP sandbox
+		"Message": pluginWidgetResizeMessage,
+		"Plugin":  pluginName,
+		"Widget":  widgetID,
+	})
+	if err != nil {
+		c.String(http.StatusInternalServerError, "Widget render error: %v", err)
+		return
+	}
+
+	c.Header("Content-Security-Policy", pluginWidgetFrameCSP)
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Header("Referrer-Policy", "no-referrer")
+	c.Header("Cache-Control", "no-store")
+	c.Data(http.StatusOK, "text/html; charset=utf-8", buf.Bytes())
+}
@@ -0,0 +1,330 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/goatkit/goatflow/internal/config"
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/service"
+)
+
+// portalDomainRequest is the JSON body accepted by the portal domain
+// create/update handlers.
+type portalDomainRequest struct {
+	Domain        string `json:"domain" binding:"required"`
+	CustomerID    string `json:"customer_id" binding:"required"`
+	Theme         string `json:"theme"`
+	LogoURL       string `json:"logo_url"`
+	SSOProviderID int    `json:"sso_provider_id"`
+	PasswordLogin *bool  `json:"password_login"`
+	QueueIDs      []int  `json:"queue_ids"`
+	ValidID       int    `json:"valid_id"`
+}
+
+func (r *portalDomainRequest) domain() *models.PortalDomain {
+	passwordLogin := true
+	if r.PasswordLogin != nil {
+		passwordLogin = *r.PasswordLogin
+	}
+	return &models.PortalDomain{
+		Domain:        r.Domain,
+		CustomerID:    r.CustomerID,
+		Theme:         r.Theme,
+		LogoURL:       r.LogoURL,
+		SSOProviderID: r.SSOProviderID,
+		PasswordLogin: passwordLogin,
+		QueueIDs:      r.QueueIDs,
+		ValidID:       r.ValidID,
+	}
+}
+
+// portalCertificateReport is the JSON body of a certificate status report.
+type portalCertificateReport struct {
+	Status  models.PortalCertStatus `json:"status" binding:"required"`
+	Message string                  `json:"message"`
+}
+
+// portalDomainService creates a portal domain service that calls the
+// configured certificate hook.
+func portalDomainService(c *gin.Context) *service.PortalDomainService {
+	db, err := database.GetDB()
+	if err != nil || db == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"success": false, "error": "Database unavailable"})
+		return nil
+	}
+	svc := service.NewPortalDomainService(db)
+	if cfg := config.Get(); cfg != nil && cfg.Integrations.CertificateHook.URL != "" {
+		hook := cfg.Integrations.CertificateHook
+		svc.SetCertificateHook(service.NewPortalCertificateWebhook(hook.URL, hook.Secret, hook.Timeout))
+	}
+	return svc
+}
+
+// portalDomainWriteError maps PortalDomainService errors to responses.
+func portalDomainWriteError(c *gin.Context, err error, action string) {
+	switch {
+	case errors.Is(err, service.ErrPortalDomainNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Portal domain not found"})
+	case errors.Is(err, service.ErrPortalDomainExists):
+		c.JSON(http.StatusConflict, gin.H{"success": false, "error": err.Error()})
+	case errors.Is(err, service.ErrPortalDomainInvalid),
+		errors.Is(err, service.ErrPortalDomainCompany),
+		errors.Is(err, service.ErrPortalDomainTheme),
+		errors.Is(err, service.ErrPortalDomainLogo),
+		errors.Is(err, service.ErrPortalDomainProvider),
+		errors.Is(err, service.ErrPortalDomainLogin),
+		errors.Is(err, service.ErrPortalDomainQueue),
+		errors.Is(err, service.ErrPortalDomainCertStatus):
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+	default:
+		log.Printf("portal domain api: %s failed: %v", action, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to " + action})
+	}
+}
+
+// portalDomainID parses the :id path parameter, writing 400 when it is invalid.
+func portalDomainID(c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid portal domain ID"})
+		return 0, false
+	}
+	return id, true
+}
+
+// HandleListPortalDomainsAPI handles GET /api/v1/portal-domains.
+//
+//	@Summary		List customer portal domains
+//	@Tags			Portal Domains
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}	"Portal domains"
+//	@Security		BearerAuth
+//	@Router			/portal-domains [get]
+func HandleListPortalDomainsAPI(c *gin.Context) {
+	svc := portalDomainService(c)
+	if svc == nil {
+		return
+	}
+	domains, err := svc.List(c.Request.Context())
+	if err != nil {
+		portalDomainWriteError(c, err, "load portal domains")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": domains})
+}
+
+// HandleCreatePortalDomainAPI handles POST /api/v1/portal-domains.
+//
+//	@Summary		Create customer portal domain
+//	@Description	Serves the customer portal for one customer company on its own host name and requests a TLS certificate through the certificate hook.
+//	@Tags			Portal Domains
+//	@Accept			json
+//	@Produce		json
+//	@Param			domain	body		object	true	"Portal domain (domain, customer_id, theme, logo_url, sso_provider_id, password_login, queue_ids)"
+//	@Success		201		{object}	map[string]interface{}	"Portal domain created"
+//	@Failure		400		{object}	map[string]interface{}	"Invalid request"
+//	@Failure		409		{object}	map[string]interface{}	"Domain already in use"
+//	@Security		BearerAuth
+//	@Router			/portal-domains [post]
+func HandleCreatePortalDomainAPI(c *gin.Context) {
+	var req portalDomainRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid portal domain: " + err.Error()})
+		return
+	}
+	svc := portalDomainService(c)
+	if svc == nil {
+		return
+	}
+	d, err := svc.Create(c.Request.Context(), req.domain(), GetUserIDFromCtx(c, 1))
+	if err != nil {
+		portalDomainWriteError(c, err, "create portal domain")
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"success": true, "data": d})
+}
+
+// HandleGetPortalDomainAPI handles GET /api/v1/portal-domains/:id.
+//
+//	@Summary		Get customer portal domain
+//	@Tags			Portal Domains
+//	@Produce		json
+//	@Param			id	path		int	true	"Portal domain ID"
+//	@Success		200	{object}	map[string]interface{}	"Portal domain"
+//	@Failure		404	{object}	map[string]interface{}	"Portal domain not found"
+//	@Security		BearerAuth
+//	@Router			/portal-domains/{id} [get]
+func HandleGetPortalDomainAPI(c *gin.Context) {
+	id, ok := portalDomainID(c)
+	if !ok {
+		return
+	}
+	svc := portalDomainService(c)
+	if svc == nil {
+		return
+	}
+	d, err := svc.Get(c.Request.Context(), id)
+	if err != nil {
+		portalDomainWriteError(c, err, "load portal domain")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": d})
+}
+
+// HandleUpdatePortalDomainAPI handles PUT /api/v1/portal-domains/:id.
+//
+//	@Summary		Update customer portal domain
+//	@Description	Renaming the domain revokes the old certificate and requests one for the new name.
+//	@Tags			Portal Domains
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int		true	"Portal domain ID"
+//	@Param			domain	body		object	true	"Portal domain"
+//	@Success		200		{object}	map[string]interface{}	"Portal domain updated"
+//	@Failure		400		{object}	map[string]interface{}	"Invalid request"
+//	@Failure		404		{object}	map[string]interface{}	"Portal domain not found"
+//	@Failure		409		{object}	map[string]interface{}	"Domain already in use"
+//	@Security		BearerAuth
+//	@Router			/portal-domains/{id} [put]
+func HandleUpdatePortalDomainAPI(c *gin.Context) {
+	id, ok := portalDomainID(c)
+	if !ok {
+		return
+	}
+	var req portalDomainRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid portal domain: " + err.Error()})
+		return
+	}
+	svc := portalDomainService(c)
+	if svc == nil {
+		return
+	}
+	d := req.domain()
+	d.ID = id
+	updated, err := svc.Update(c.Request.Context(), d, GetUserIDFromCtx(c, 1))
+	if err != nil {
+		portalDomainWriteError(c, err, "update portal domain")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": updated})
+}
+
+// HandleDeletePortalDomainAPI handles DELETE /api/v1/portal-domains/:id.
+//
+//	@Summary		Delete customer portal domain
+//	@Description	Removes the domain and asks the certificate hook to revoke its certificate.
+//	@Tags			Portal Domains
+//	@Produce		json
+//	@Param			id	path		int	true	"Portal domain ID"
+//	@Success		200	{object}	map[string]interface{}	"Portal domain deleted"
+//	@Failure		404	{object}	map[string]interface{}	"Portal domain not found"
+//	@Security		BearerAuth
+//	@Router			/portal-domains/{id} [delete]
+func HandleDeletePortalDomainAPI(c *gin.Context) {
+	id, ok := portalDomainID(c)
+	if !ok {
+		return
+	}
+	svc := portalDomainService(c)
+	if svc == nil {
+		return
+	}
+	if err := svc.Delete(c.Request.Context(), id); err != nil {
+		portalDomainWriteError(c, err, "delete portal domain")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// HandleRequestPortalCertificateAPI handles POST /api/v1/portal-domains/:id/certificate.
+//
+//	@Summary		Request portal domain certificate
+//	@Description	Calls the certificate hook again, e.g. after a failed request or once DNS points at the server.
+//	@Tags			Portal Domains
+//	@Produce		json
+//	@Param			id	path		int	true	"Portal domain ID"
+//	@Success		200	{object}	map[string]interface{}	"Portal domain with its certificate status"
+//	@Failure		404	{object}	map[string]interface{}	"Portal domain not found"
+//	@Security		BearerAuth
+//	@Router			/portal-domains/{id}/certificate [post]
+func HandleRequestPortalCertificateAPI(c *gin.Context) {
+	id, ok := portalDomainID(c)
+	if !ok {
+		return
+	}
+	svc := portalDomainService(c)
+	if svc == nil {
+		return
+	}
+	d, err := svc.RequestCertificate(c.Request.Context(), id)
+	if err != nil {
+		portalDomainWriteError(c, err, "request certificate")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": d})
+}
+
+// HandleReportPortalCertificateAPI handles PUT /api/v1/portal-domains/:id/certificate.
+//
+//	@Summary		Report portal domain certificate status
+//	@Description	Called by the provisioning system once a certificate is issued or has failed.
+//	@Tags			Portal Domains
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int		true	"Portal domain ID"
+//	@Param			report	body		object	true	"Certificate status (status, message)"
+//	@Success		200		{object}	map[string]interface{}	"Portal domain with its certificate status"
+//	@Failure		400		{object}	map[string]interface{}	"Invalid status"
+//	@Failure		404		{object}	map[string]interface{}	"Portal domain not found"
+//	@Security		BearerAuth
+//	@Router			/portal-domains/{id}/certificate [put]
+func HandleReportPortalCertificateAPI(c *gin.Context) {
+	id, ok := portalDomainID(c)
+	if !ok {
+		return
+	}
+	var req portalCertificateReport
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid certificate report: " + err.Error()})
+		return
+	}
+	if !req.Status.IsValid() {
+		portalDomainWriteError(c, service.ErrPortalDomainCertStatus, "report certificate")
+		return
+	}
+	svc := portalDomainService(c)
+	if svc == nil {
+		return
+	}
+	d, err := svc.ReportCertificate(c.Request.Context(), id, req.Status, req.Message)
+	if err != nil {
+		portalDomainWriteError(c, err, "report certificate")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": d})
+}
+
+// handlePortalDomainTLSCheck answers on-demand TLS checks from the reverse
+// proxy (Caddy's on_demand_tls ask): 200 when the domain is an enabled
+// portal domain and certificates may be issued for it, 404 otherwise.
+func handlePortalDomainTLSCheck(c *gin.Context) {
+	host := c.Query("domain")
+	db, err := database.GetDB()
+	if host == "" || err != nil || db == nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	d, err := service.NewPortalDomainService(db).Resolve(c.Request.Context(), host)
+	if err != nil || d == nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	c.Status(http.StatusOK)
+}
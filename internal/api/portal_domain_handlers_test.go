@@ -0,0 +1,41 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPortalDomainHandlers_InvalidInput(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.GET("/api/v1/portal-domains/:id", HandleGetPortalDomainAPI)
+	router.POST("/api/v1/portal-domains", HandleCreatePortalDomainAPI)
+	router.PUT("/api/v1/portal-domains/:id/certificate", HandleReportPortalCertificateAPI)
+
+	for _, r := range []struct{ method, path, body, want string }{
+		{http.MethodGet, "/api/v1/portal-domains/abc", "", "Invalid portal domain ID"},
+		{http.MethodPost, "/api/v1/portal-domains", `{"customer_id":"acme"}`, "Invalid portal domain"},
+		{http.MethodPut, "/api/v1/portal-domains/0/certificate", `{"status":"issued"}`, "Invalid portal domain ID"},
+		{http.MethodPut, "/api/v1/portal-domains/1/certificate", `{"status":"done"}`, "status must be one of"},
+	} {
+		req := httptest.NewRequest(r.method, r.path, strings.NewReader(r.body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, r.path)
+		assert.Contains(t, w.Body.String(), r.want, r.path)
+	}
+}
+
+func TestPortalDomainRequest_PasswordLoginDefaultsOn(t *testing.T) {
+	off := false
+	assert.True(t, (&portalDomainRequest{Domain: "help.acme.test"}).domain().PasswordLogin)
+	assert.False(t, (&portalDomainRequest{Domain: "help.acme.test", PasswordLogin: &off}).domain().PasswordLogin)
+}
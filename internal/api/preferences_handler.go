@@ -864,9 +864,10 @@ func HandleAgentPasswordForm(c *gin.Context) {
 	}
 
 	getPongo2Renderer().HTML(c, http.StatusOK, "pages/password_form.pongo2", pongo2.Context{
-		"Title":      "Change Password",
-		"ActivePage": "profile",
-		"Policy":     policy,
+		"Title":           "Change Password",
+		"ActivePage":      "profile",
+		"Policy":          policy,
+		"PasswordExpired": c.Query("expired") == "1",
 		"User": map[string]interface{}{
 			"Login":     user.Login,
 			"FirstName": user.FirstName,
@@ -937,10 +938,10 @@ func HandleAgentChangePassword(c *gin.Context) {
 	}
 
 	// Get current password hash from database
-	var currentHash string
+	var login, currentHash string
 	err = db.QueryRow(database.ConvertPlaceholders(`
-		SELECT pw FROM users WHERE id = ? AND valid_id = 1
-	`), userID).Scan(&currentHash)
+		SELECT login, pw FROM users WHERE id = ? AND valid_id = 1
+	`), userID).Scan(&login, &currentHash)
 
 	if err != nil {
 		log.Printf("Error getting agent password for user %d: %v", userID, err)
@@ -970,17 +971,21 @@ func HandleAgentChangePassword(c *gin.Context) {
 		return
 	}
 
-	// Load and validate against password policy
-	policy, err := sysconfig.LoadAgentPasswordPolicy(db)
+	// Validate against the password policy, history and breaches
+	policyService := service.NewPasswordPolicyService(db)
+	validationErr, err := policyService.CheckPassword(c.Request.Context(), service.PasswordAccountAgent, login, request.NewPassword)
 	if err != nil {
-		log.Printf("Error loading agent password policy: %v", err)
-		policy = sysconfig.DefaultAgentPasswordPolicy()
+		log.Printf("Error checking agent password policy: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"error":   "Failed to check password policy",
+		})
+		return
 	}
-
-	if validationErr := policy.ValidatePassword(request.NewPassword); validationErr != nil {
+	if validationErr != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"success": false,
-			"error":   validationErr.Message,
+			"error":   getPasswordPolicyErrorMessage(validationErr.Code),
 			"code":    validationErr.Code,
 		})
 		return
@@ -1011,6 +1016,10 @@ func HandleAgentChangePassword(c *gin.Context) {
 		return
 	}
 
+	if err := policyService.RecordChange(c.Request.Context(), service.PasswordAccountAgent, login, newHash); err != nil {
+		log.Printf("Error recording password history for agent %s: %v", login, err)
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "Password changed successfully",
@@ -0,0 +1,409 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/service"
+)
+
+// priorityMatrixLevelRequest is the JSON body accepted by the admin level
+// create/update handlers. The kind is only read on create.
+type priorityMatrixLevelRequest struct {
+	Kind     string `json:"kind"`
+	Name     string `json:"name" binding:"required"`
+	Position int    `json:"position"`
+	ValidID  int    `json:"valid_id"`
+}
+
+// priorityMatrixRequest is the JSON body accepted by the matrix update
+// handler.
+type priorityMatrixRequest struct {
+	Cells []models.PriorityMatrixCell `json:"cells"`
+}
+
+// priorityMatrixService returns the service, writing 503 when the database
+// is unavailable.
+func priorityMatrixService(c *gin.Context) *service.PriorityMatrixService {
+	db, err := database.GetDB()
+	if err != nil || db == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"success": false, "error": "Database unavailable"})
+		return nil
+	}
+	return service.NewPriorityMatrixService(db)
+}
+
+// priorityMatrixError maps PriorityMatrixService errors to responses.
+func priorityMatrixError(c *gin.Context, err error, action string) {
+	switch {
+	case errors.Is(err, service.ErrPriorityMatrixLevelNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Level not found"})
+	case errors.Is(err, service.ErrPriorityMatrixQueueNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Queue not found"})
+	case errors.Is(err, service.ErrPriorityMatrixLevelNameExists), errors.Is(err, service.ErrPriorityMatrixLevelInUse):
+		c.JSON(http.StatusConflict, gin.H{"success": false, "error": err.Error()})
+	case errors.Is(err, service.ErrPriorityMatrixInvalid):
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+	default:
+		log.Printf("priority matrix api: %s failed: %v", action, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to " + action})
+	}
+}
+
+// priorityMatrixLevelID parses the :id path parameter, writing 400 when it
+// is invalid.
+func priorityMatrixLevelID(c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid level ID"})
+		return 0, false
+	}
+	return id, true
+}
+
+// priorityMatrixQueueID parses the optional queue_id query parameter; 0
+// selects the default matrix. It writes 400 when the parameter is invalid.
+func priorityMatrixQueueID(c *gin.Context) (int, bool) {
+	raw := c.Query("queue_id")
+	if raw == "" {
+		return 0, true
+	}
+	id, err := strconv.Atoi(raw)
+	if err != nil || id < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid queue ID"})
+		return 0, false
+	}
+	return id, true
+}
+
+// ticketRatingID returns a rating ID for a ticket response, nil when the
+// ticket is not rated.
+func ticketRatingID(id int) interface{} {
+	if id <= 0 {
+		return nil
+	}
+	return id
+}
+
+// addTicketRating adds the ticket's impact_id and urgency_id to a ticket
+// response.
+func addTicketRating(ctx context.Context, db *sql.DB, ticketID int64, data gin.H) {
+	rating, err := service.NewPriorityMatrixService(db).TicketRating(ctx, ticketID)
+	if err != nil {
+		log.Printf("priority matrix: loading rating of ticket %d failed: %v", ticketID, err)
+		return
+	}
+	data["impact_id"] = ticketRatingID(rating.ImpactID)
+	data["urgency_id"] = ticketRatingID(rating.UrgencyID)
+}
+
+// checkTicketRating checks an impact or urgency a ticket request sets. It
+// writes 400 or 500 and returns false when the level cannot be used.
+func checkTicketRating(c *gin.Context, svc *service.PriorityMatrixService, kind string, id int) bool {
+	err := svc.CheckLevel(c.Request.Context(), kind, id)
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, service.ErrPriorityMatrixInvalid):
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid " + kind + "_id"})
+	default:
+		log.Printf("priority matrix: checking %s %d failed: %v", kind, id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to check " + kind})
+	}
+	return false
+}
+
+// deriveNewTicketPriority checks the rating of a ticket about to be created
+// in the queue and returns the priority the matrix derives from it, 0 when
+// it derives none. It writes an error response and returns false when the
+// rating is invalid.
+func deriveNewTicketPriority(c *gin.Context, db *sql.DB, queueID int, rating *models.TicketImpactUrgency) (int, bool) {
+	if rating.ImpactID == 0 && rating.UrgencyID == 0 {
+		return 0, true
+	}
+	svc := service.NewPriorityMatrixService(db)
+	if !checkTicketRating(c, svc, models.PriorityMatrixImpact, rating.ImpactID) {
+		return 0, false
+	}
+	if !checkTicketRating(c, svc, models.PriorityMatrixUrgency, rating.UrgencyID) {
+		return 0, false
+	}
+	priorityID, err := svc.Derive(c.Request.Context(), queueID, rating.ImpactID, rating.UrgencyID)
+	if err != nil {
+		log.Printf("priority matrix: deriving priority in queue %d failed: %v", queueID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to derive priority"})
+		return 0, false
+	}
+	return priorityID, true
+}
+
+// applyTicketPriorityMatrix handles impact_id and urgency_id in a ticket
+// update; null clears them. Unless the request sets priority_id, it adds
+// the priority the matrix of the ticket's (new) queue derives from the
+// rating, so moving a rated ticket re-derives its priority too. It returns
+// the rating to store once the ticket is updated, nil when the rating does
+// not change. When it writes an error response it returns false.
+func applyTicketPriorityMatrix(c *gin.Context, db *sql.DB, ticketID int64, updateRequest map[string]interface{}) (*models.TicketImpactUrgency, bool) {
+	rawImpact, hasImpact := updateRequest["impact_id"]
+	rawUrgency, hasUrgency := updateRequest["urgency_id"]
+	newQueue, hasQueue := updateRequest["queue_id"].(float64)
+	if !hasImpact && !hasUrgency && !hasQueue {
+		return nil, true
+	}
+
+	ctx := c.Request.Context()
+	svc := service.NewPriorityMatrixService(db)
+	rating, err := svc.TicketRating(ctx, ticketID)
+	if err != nil {
+		log.Printf("priority matrix: loading rating of ticket %d failed: %v", ticketID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to load ticket rating"})
+		return nil, false
+	}
+	for _, field := range []struct {
+		kind string
+		raw  interface{}
+		set  bool
+		dst  *int
+	}{
+		{models.PriorityMatrixImpact, rawImpact, hasImpact, &rating.ImpactID},
+		{models.PriorityMatrixUrgency, rawUrgency, hasUrgency, &rating.UrgencyID},
+	} {
+		if !field.set {
+			continue
+		}
+		id := 0
+		if field.raw != nil {
+			v, ok := field.raw.(float64)
+			if !ok || v <= 0 || v != float64(int(v)) {
+				c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid " + field.kind + "_id"})
+				return nil, false
+			}
+			id = int(v)
+		}
+		if !checkTicketRating(c, svc, field.kind, id) {
+			return nil, false
+		}
+		*field.dst = id
+	}
+
+	if _, explicit := updateRequest["priority_id"]; !explicit && rating.ImpactID > 0 && rating.UrgencyID > 0 {
+		queueID := int(newQueue)
+		if !hasQueue {
+			if err := db.QueryRowContext(ctx, database.ConvertPlaceholders(
+				"SELECT queue_id FROM ticket WHERE id = ?"), ticketID).Scan(&queueID); err != nil {
+				log.Printf("priority matrix: loading queue of ticket %d failed: %v", ticketID, err)
+				c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to derive priority"})
+				return nil, false
+			}
+		}
+		priorityID, err := svc.Derive(ctx, queueID, rating.ImpactID, rating.UrgencyID)
+		if err != nil {
+			log.Printf("priority matrix: deriving priority of ticket %d failed: %v", ticketID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to derive priority"})
+			return nil, false
+		}
+		if priorityID > 0 {
+			updateRequest["priority_id"] = float64(priorityID)
+		}
+	}
+
+	if !hasImpact && !hasUrgency {
+		return nil, true
+	}
+	return rating, true
+}
+
+// HandleGetPriorityMatrixAPI handles GET /api/v1/priority-matrix.
+//
+//	@Summary		Get priority matrix
+//	@Description	Valid impact and urgency levels and the cells mapping them to priorities. With queue_id, the queue's own cells replace the default matrix's; each cell's queue_id tells where it comes from.
+//	@Tags			Priority Matrix
+//	@Produce		json
+//	@Param			queue_id	query		int	false	"Queue ID; omit for the default matrix"
+//	@Success		200			{object}	map[string]interface{}	"Matrix"
+//	@Failure		404			{object}	map[string]interface{}	"Queue not found"
+//	@Security		BearerAuth
+//	@Router			/priority-matrix [get]
+func HandleGetPriorityMatrixAPI(c *gin.Context) {
+	queueID, ok := priorityMatrixQueueID(c)
+	if !ok {
+		return
+	}
+	svc := priorityMatrixService(c)
+	if svc == nil {
+		return
+	}
+	matrix, err := svc.GetMatrix(c.Request.Context(), queueID)
+	if err != nil {
+		priorityMatrixError(c, err, "load priority matrix")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": matrix})
+}
+
+// HandleSetPriorityMatrixAPI handles PUT /api/v1/admin/priority-matrix.
+//
+//	@Summary		Set priority matrix
+//	@Description	Replaces the cells of the default matrix, or with queue_id the cells the queue overrides. An empty list removes a queue's overrides.
+//	@Tags			Priority Matrix
+//	@Accept			json
+//	@Produce		json
+//	@Param			queue_id	query		int		false	"Queue ID; omit for the default matrix"
+//	@Param			matrix		body		object	true	"Cells (impact_id, urgency_id, priority_id)"
+//	@Success		200			{object}	map[string]interface{}	"Effective matrix"
+//	@Failure		400			{object}	map[string]interface{}	"Invalid request"
+//	@Failure		404			{object}	map[string]interface{}	"Queue not found"
+//	@Security		BearerAuth
+//	@Router			/admin/priority-matrix [put]
+func HandleSetPriorityMatrixAPI(c *gin.Context) {
+	queueID, ok := priorityMatrixQueueID(c)
+	if !ok {
+		return
+	}
+	var req priorityMatrixRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid priority matrix request: " + err.Error()})
+		return
+	}
+	svc := priorityMatrixService(c)
+	if svc == nil {
+		return
+	}
+	ctx := c.Request.Context()
+	if err := svc.SetMatrix(ctx, queueID, req.Cells, GetUserIDFromCtx(c, 1)); err != nil {
+		priorityMatrixError(c, err, "update priority matrix")
+		return
+	}
+	matrix, err := svc.GetMatrix(ctx, queueID)
+	if err != nil {
+		priorityMatrixError(c, err, "load priority matrix")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": matrix})
+}
+
+// HandleAdminListPriorityMatrixLevelsAPI handles GET /api/v1/admin/priority-matrix/levels.
+//
+//	@Summary		List impact and urgency levels
+//	@Tags			Priority Matrix
+//	@Produce		json
+//	@Param			kind	query		string	false	"impact or urgency"
+//	@Success		200		{object}	map[string]interface{}	"Levels, including invalid ones"
+//	@Failure		400		{object}	map[string]interface{}	"Invalid kind"
+//	@Security		BearerAuth
+//	@Router			/admin/priority-matrix/levels [get]
+func HandleAdminListPriorityMatrixLevelsAPI(c *gin.Context) {
+	svc := priorityMatrixService(c)
+	if svc == nil {
+		return
+	}
+	levels, err := svc.ListLevels(c.Request.Context(), c.Query("kind"), false)
+	if err != nil {
+		priorityMatrixError(c, err, "load priority matrix levels")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": levels})
+}
+
+// HandleCreatePriorityMatrixLevelAPI handles POST /api/v1/admin/priority-matrix/levels.
+//
+//	@Summary		Create impact or urgency level
+//	@Tags			Priority Matrix
+//	@Accept			json
+//	@Produce		json
+//	@Param			level	body		object	true	"Level (kind, name, position, valid_id)"
+//	@Success		201		{object}	map[string]interface{}	"Level created"
+//	@Failure		400		{object}	map[string]interface{}	"Invalid request"
+//	@Failure		409		{object}	map[string]interface{}	"Name already in use"
+//	@Security		BearerAuth
+//	@Router			/admin/priority-matrix/levels [post]
+func HandleCreatePriorityMatrixLevelAPI(c *gin.Context) {
+	var req priorityMatrixLevelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid level request: " + err.Error()})
+		return
+	}
+	svc := priorityMatrixService(c)
+	if svc == nil {
+		return
+	}
+	level := &models.PriorityMatrixLevel{Kind: req.Kind, Name: req.Name, Position: req.Position, ValidID: req.ValidID}
+	if err := svc.CreateLevel(c.Request.Context(), level, GetUserIDFromCtx(c, 1)); err != nil {
+		priorityMatrixError(c, err, "create priority matrix level")
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"success": true, "data": level})
+}
+
+// HandleUpdatePriorityMatrixLevelAPI handles PUT /api/v1/admin/priority-matrix/levels/:id.
+//
+//	@Summary		Update impact or urgency level
+//	@Description	Changes the name, position and validity; the kind of a level cannot change.
+//	@Tags			Priority Matrix
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int		true	"Level ID"
+//	@Param			level	body		object	true	"Level"
+//	@Success		200		{object}	map[string]interface{}	"Level updated"
+//	@Failure		400		{object}	map[string]interface{}	"Invalid request"
+//	@Failure		404		{object}	map[string]interface{}	"Level not found"
+//	@Failure		409		{object}	map[string]interface{}	"Name already in use"
+//	@Security		BearerAuth
+//	@Router			/admin/priority-matrix/levels/{id} [put]
+func HandleUpdatePriorityMatrixLevelAPI(c *gin.Context) {
+	id, ok := priorityMatrixLevelID(c)
+	if !ok {
+		return
+	}
+	var req priorityMatrixLevelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid level request: " + err.Error()})
+		return
+	}
+	svc := priorityMatrixService(c)
+	if svc == nil {
+		return
+	}
+	level := &models.PriorityMatrixLevel{ID: id, Name: req.Name, Position: req.Position, ValidID: req.ValidID}
+	if err := svc.UpdateLevel(c.Request.Context(), level, GetUserIDFromCtx(c, 1)); err != nil {
+		priorityMatrixError(c, err, "update priority matrix level")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": level})
+}
+
+// HandleDeletePriorityMatrixLevelAPI handles DELETE /api/v1/admin/priority-matrix/levels/:id.
+//
+//	@Summary		Delete impact or urgency level
+//	@Description	Deletes the level and its matrix cells. Levels tickets are rated with cannot be deleted; set valid_id to 2 to retire them.
+//	@Tags			Priority Matrix
+//	@Produce		json
+//	@Param			id	path		int	true	"Level ID"
+//	@Success		200	{object}	map[string]interface{}	"Level deleted"
+//	@Failure		404	{object}	map[string]interface{}	"Level not found"
+//	@Failure		409	{object}	map[string]interface{}	"Level in use"
+//	@Security		BearerAuth
+//	@Router			/admin/priority-matrix/levels/{id} [delete]
+func HandleDeletePriorityMatrixLevelAPI(c *gin.Context) {
+	id, ok := priorityMatrixLevelID(c)
+	if !ok {
+		return
+	}
+	svc := priorityMatrixService(c)
+	if svc == nil {
+		return
+	}
+	if err := svc.DeleteLevel(c.Request.Context(), id); err != nil {
+		priorityMatrixError(c, err, "delete priority matrix level")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
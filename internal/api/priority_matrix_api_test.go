@@ -0,0 +1,132 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/service"
+	"github.com/goatkit/goatflow/internal/testutil"
+)
+
+func TestPriorityMatrixHandlers_InvalidRequest(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("user_id", 1)
+		c.Next()
+	})
+	router.GET("/api/v1/priority-matrix", HandleGetPriorityMatrixAPI)
+	router.PUT("/api/v1/admin/priority-matrix", HandleSetPriorityMatrixAPI)
+	router.POST("/api/v1/admin/priority-matrix/levels", HandleCreatePriorityMatrixLevelAPI)
+	router.PUT("/api/v1/admin/priority-matrix/levels/:id", HandleUpdatePriorityMatrixLevelAPI)
+	router.DELETE("/api/v1/admin/priority-matrix/levels/:id", HandleDeletePriorityMatrixLevelAPI)
+
+	for _, tc := range []struct {
+		method string
+		path   string
+		body   string
+		want   string
+	}{
+		{http.MethodGet, "/api/v1/priority-matrix?queue_id=raw", "", "Invalid queue ID"},
+		{http.MethodPut, "/api/v1/admin/priority-matrix?queue_id=-1", `{"cells": []}`, "Invalid queue ID"},
+		{http.MethodPut, "/api/v1/admin/priority-matrix", `{"cells": {}}`, "Invalid priority matrix request"},
+		{http.MethodPost, "/api/v1/admin/priority-matrix/levels", `{"kind": "impact"}`, "Invalid level request"},
+		{http.MethodPut, "/api/v1/admin/priority-matrix/levels/0", `{"name": "High"}`, "Invalid level ID"},
+		{http.MethodDelete, "/api/v1/admin/priority-matrix/levels/abc", "", "Invalid level ID"},
+	} {
+		t.Run(tc.method+" "+tc.path+" "+tc.body, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Contains(t, w.Body.String(), tc.want)
+		})
+	}
+}
+
+func TestApplyTicketPriorityMatrix(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := testutil.UseMigratedDB(t)
+	_, err := db.Exec(`INSERT INTO users (id, login, pw, first_name, last_name, valid_id, create_time, create_by, change_time, change_by)
+		VALUES (1, 'root@localhost', 'x', 'Admin', 'OTRS', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`)
+	require.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO ticket (id, tn, title, queue_id, ticket_lock_id, user_id, responsible_user_id,
+		ticket_priority_id, ticket_state_id, timeout, until_time, escalation_time, escalation_update_time,
+		escalation_response_time, escalation_solution_time, archive_flag, create_time, create_by, change_time, change_by)
+		VALUES (1, '2026031010000001', 'Mail down', 1, 1, 1, 1, 3, 1, 0, 0, 0, 0, 0, 0, 0,
+		        CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	svc := service.NewPriorityMatrixService(db)
+	high := &models.PriorityMatrixLevel{Kind: models.PriorityMatrixImpact, Name: "High"}
+	urgent := &models.PriorityMatrixLevel{Kind: models.PriorityMatrixUrgency, Name: "High"}
+	require.NoError(t, svc.CreateLevel(ctx, high, 1))
+	require.NoError(t, svc.CreateLevel(ctx, urgent, 1))
+	require.NoError(t, svc.SetMatrix(ctx, 0, []models.PriorityMatrixCell{{ImpactID: high.ID, UrgencyID: urgent.ID, PriorityID: 4}}, 1))
+	require.NoError(t, svc.SetMatrix(ctx, 2, []models.PriorityMatrixCell{{ImpactID: high.ID, UrgencyID: urgent.ID, PriorityID: 5}}, 1))
+
+	apply := func(body string) (map[string]interface{}, *httptest.ResponseRecorder, *models.TicketImpactUrgency, bool) {
+		var updateRequest map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(body), &updateRequest))
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPut, "/api/v1/tickets/1", nil)
+		rating, ok := applyTicketPriorityMatrix(c, db, 1, updateRequest)
+		return updateRequest, w, rating, ok
+	}
+
+	req, _, rating, ok := apply(`{"title": "Mail still down"}`)
+	require.True(t, ok)
+	assert.Nil(t, rating)
+	assert.NotContains(t, req, "priority_id")
+
+	req, _, rating, ok = apply(`{"impact_id": ` + strconv.Itoa(high.ID) + `}`)
+	require.True(t, ok)
+	assert.Equal(t, &models.TicketImpactUrgency{TicketID: 1, ImpactID: high.ID}, rating)
+	assert.NotContains(t, req, "priority_id", "both ratings are needed")
+
+	req, _, rating, ok = apply(`{"impact_id": ` + strconv.Itoa(high.ID) + `, "urgency_id": ` + strconv.Itoa(urgent.ID) + `}`)
+	require.True(t, ok)
+	require.NotNil(t, rating)
+	assert.Equal(t, float64(4), req["priority_id"])
+	require.NoError(t, svc.RateTicket(ctx, rating, 1))
+
+	req, _, rating, ok = apply(`{"queue_id": 2}`)
+	require.True(t, ok)
+	assert.Nil(t, rating, "moving keeps the rating")
+	assert.Equal(t, float64(5), req["priority_id"], "the new queue's override applies")
+
+	req, _, _, ok = apply(`{"urgency_id": ` + strconv.Itoa(urgent.ID) + `, "priority_id": 1}`)
+	require.True(t, ok)
+	assert.Equal(t, float64(1), req["priority_id"], "an explicit priority wins")
+
+	req, _, rating, ok = apply(`{"urgency_id": null}`)
+	require.True(t, ok)
+	assert.Equal(t, 0, rating.UrgencyID)
+	assert.NotContains(t, req, "priority_id")
+
+	for _, body := range []string{
+		`{"impact_id": "high"}`,
+		`{"impact_id": 1.5}`,
+		`{"impact_id": ` + strconv.Itoa(urgent.ID) + `}`,
+		`{"urgency_id": 9999}`,
+	} {
+		_, w, _, ok := apply(body)
+		assert.False(t, ok, body)
+		assert.Equal(t, http.StatusBadRequest, w.Code, body)
+	}
+}
@@ -0,0 +1,295 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/service"
+)
+
+// privacyRequestBody is the JSON body of HandleCreatePrivacyRequestAPI.
+type privacyRequestBody struct {
+	Kind        models.PrivacyRequestKind            `json:"kind" binding:"required"`
+	Subject     string                               `json:"subject" binding:"required"`
+	FieldPolicy map[string]models.PrivacyFieldAction `json:"field_policy"`
+	Reason      string                               `json:"reason"`
+}
+
+// privacyDecisionBody is the optional JSON body of the approve and reject
+// handlers.
+type privacyDecisionBody struct {
+	Comment string `json:"comment"`
+}
+
+// privacyService returns the service, writing 503 when the database is
+// unavailable.
+func privacyService(c *gin.Context) *service.PrivacyService {
+	db, err := database.GetDB()
+	if err != nil || db == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"success": false, "error": "Database unavailable"})
+		return nil
+	}
+	return service.NewPrivacyService(db)
+}
+
+// privacyError maps PrivacyService errors to responses.
+func privacyError(c *gin.Context, err error, action string) {
+	switch {
+	case errors.Is(err, service.ErrPrivacyRequestNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Privacy request not found"})
+	case errors.Is(err, service.ErrPrivacySubjectNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": err.Error()})
+	case errors.Is(err, service.ErrPrivacyNotPending),
+		errors.Is(err, service.ErrPrivacyExportNotReady):
+		c.JSON(http.StatusConflict, gin.H{"success": false, "error": err.Error()})
+	case errors.Is(err, service.ErrPrivacySelfApproval):
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": err.Error()})
+	case errors.Is(err, service.ErrPrivacyKind),
+		errors.Is(err, service.ErrPrivacyFieldPolicy):
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+	default:
+		log.Printf("privacy api: %s failed: %v", action, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to " + action})
+	}
+}
+
+// privacyRequestID parses the :id path parameter, writing 400 when it is
+// invalid.
+func privacyRequestID(c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid privacy request ID"})
+		return 0, false
+	}
+	return id, true
+}
+
+// bindPrivacyDecision reads the optional comment of a decision, writing 400
+// when the body is not valid JSON.
+func bindPrivacyDecision(c *gin.Context) (string, bool) {
+	var body privacyDecisionBody
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&body); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid decision: " + err.Error()})
+			return "", false
+		}
+	}
+	return body.Comment, true
+}
+
+// HandleListPrivacyFieldsAPI handles GET /api/v1/admin/privacy/fields.
+//
+//	@Summary		List erasure fields
+//	@Description	The fields of an erasure's field policy with their supported and default actions.
+//	@Tags			Privacy
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}	"Fields"
+//	@Security		BearerAuth
+//	@Router			/admin/privacy/fields [get]
+func HandleListPrivacyFieldsAPI(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": models.PrivacyFields})
+}
+
+// HandleListPrivacyRequestsAPI handles GET /api/v1/admin/privacy/requests.
+//
+//	@Summary		List privacy requests
+//	@Tags			Privacy
+//	@Produce		json
+//	@Param			status	query		string	false	"Only requests with this status"
+//	@Param			limit	query		int		false	"Maximum requests (default 50)"
+//	@Success		200		{object}	map[string]interface{}	"Privacy requests, newest first"
+//	@Security		BearerAuth
+//	@Router			/admin/privacy/requests [get]
+func HandleListPrivacyRequestsAPI(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if limit <= 0 || limit > 500 {
+		limit = 50
+	}
+	svc := privacyService(c)
+	if svc == nil {
+		return
+	}
+	requests, err := svc.List(c.Request.Context(), models.PrivacyRequestStatus(c.Query("status")), limit)
+	if err != nil {
+		privacyError(c, err, "load privacy requests")
+		return
+	}
+	if requests == nil {
+		requests = []*models.PrivacyRequest{}
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": requests})
+}
+
+// HandleCreatePrivacyRequestAPI handles POST /api/v1/admin/privacy/requests.
+//
+//	@Summary		Create privacy request
+//	@Description	Records an export or erasure of a customer user's data, found by login or email. It runs once approved.
+//	@Tags			Privacy
+//	@Accept			json
+//	@Produce		json
+//	@Param			request	body		object	true	"Request (kind, subject, field_policy, reason)"
+//	@Success		201		{object}	map[string]interface{}	"Privacy request created"
+//	@Failure		400		{object}	map[string]interface{}	"Invalid request"
+//	@Failure		404		{object}	map[string]interface{}	"No matching customer"
+//	@Security		BearerAuth
+//	@Router			/admin/privacy/requests [post]
+func HandleCreatePrivacyRequestAPI(c *gin.Context) {
+	var body privacyRequestBody
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid privacy request: " + err.Error()})
+		return
+	}
+	svc := privacyService(c)
+	if svc == nil {
+		return
+	}
+	req, err := svc.CreateRequest(c.Request.Context(), body.Kind, body.Subject, body.FieldPolicy, body.Reason, GetUserIDFromCtx(c, 1))
+	if err != nil {
+		privacyError(c, err, "create privacy request")
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"success": true, "data": req})
+}
+
+// HandleGetPrivacyRequestAPI handles GET /api/v1/admin/privacy/requests/:id.
+//
+//	@Summary		Get privacy request
+//	@Description	The request with its audit trail.
+//	@Tags			Privacy
+//	@Produce		json
+//	@Param			id	path		int	true	"Privacy request ID"
+//	@Success		200	{object}	map[string]interface{}	"Privacy request"
+//	@Failure		404	{object}	map[string]interface{}	"Privacy request not found"
+//	@Security		BearerAuth
+//	@Router			/admin/privacy/requests/{id} [get]
+func HandleGetPrivacyRequestAPI(c *gin.Context) {
+	id, ok := privacyRequestID(c)
+	if !ok {
+		return
+	}
+	svc := privacyService(c)
+	if svc == nil {
+		return
+	}
+	req, err := svc.Get(c.Request.Context(), id)
+	if err != nil {
+		privacyError(c, err, "load privacy request")
+		return
+	}
+	logs, err := svc.Logs(c.Request.Context(), id)
+	if err != nil {
+		privacyError(c, err, "load privacy request log")
+		return
+	}
+	if logs == nil {
+		logs = []*models.PrivacyRequestLog{}
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": req, "log": logs})
+}
+
+// HandleApprovePrivacyRequestAPI handles POST /api/v1/admin/privacy/requests/:id/approve.
+//
+//	@Summary		Approve privacy request
+//	@Description	Approves a pending request and queues it as a background job. Erasures need an admin other than the requester.
+//	@Tags			Privacy
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int		true	"Privacy request ID"
+//	@Param			body	body		object	false	"Decision (comment)"
+//	@Success		200		{object}	map[string]interface{}	"Privacy request approved"
+//	@Failure		403		{object}	map[string]interface{}	"Requester cannot approve the erasure"
+//	@Failure		404		{object}	map[string]interface{}	"Privacy request not found"
+//	@Failure		409		{object}	map[string]interface{}	"Privacy request not pending"
+//	@Security		BearerAuth
+//	@Router			/admin/privacy/requests/{id}/approve [post]
+func HandleApprovePrivacyRequestAPI(c *gin.Context) {
+	id, ok := privacyRequestID(c)
+	if !ok {
+		return
+	}
+	comment, ok := bindPrivacyDecision(c)
+	if !ok {
+		return
+	}
+	svc := privacyService(c)
+	if svc == nil {
+		return
+	}
+	req, err := svc.Approve(c.Request.Context(), id, GetUserIDFromCtx(c, 1), comment)
+	if err != nil {
+		privacyError(c, err, "approve privacy request")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": req})
+}
+
+// HandleRejectPrivacyRequestAPI handles POST /api/v1/admin/privacy/requests/:id/reject.
+//
+//	@Summary		Reject privacy request
+//	@Tags			Privacy
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int		true	"Privacy request ID"
+//	@Param			body	body		object	false	"Decision (comment)"
+//	@Success		200		{object}	map[string]interface{}	"Privacy request rejected"
+//	@Failure		404		{object}	map[string]interface{}	"Privacy request not found"
+//	@Failure		409		{object}	map[string]interface{}	"Privacy request not pending"
+//	@Security		BearerAuth
+//	@Router			/admin/privacy/requests/{id}/reject [post]
+func HandleRejectPrivacyRequestAPI(c *gin.Context) {
+	id, ok := privacyRequestID(c)
+	if !ok {
+		return
+	}
+	comment, ok := bindPrivacyDecision(c)
+	if !ok {
+		return
+	}
+	svc := privacyService(c)
+	if svc == nil {
+		return
+	}
+	req, err := svc.Reject(c.Request.Context(), id, GetUserIDFromCtx(c, 1), comment)
+	if err != nil {
+		privacyError(c, err, "reject privacy request")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": req})
+}
+
+// HandleDownloadPrivacyExportAPI handles GET /api/v1/admin/privacy/requests/:id/export.
+//
+//	@Summary		Download privacy export
+//	@Description	The ZIP of a completed export. Every download is recorded in the request's audit trail.
+//	@Tags			Privacy
+//	@Produce		application/zip
+//	@Param			id	path		int	true	"Privacy request ID"
+//	@Success		200	{file}		binary	"ZIP archive"
+//	@Failure		404	{object}	map[string]interface{}	"Privacy request not found"
+//	@Failure		409	{object}	map[string]interface{}	"Export not available"
+//	@Security		BearerAuth
+//	@Router			/admin/privacy/requests/{id}/export [get]
+func HandleDownloadPrivacyExportAPI(c *gin.Context) {
+	id, ok := privacyRequestID(c)
+	if !ok {
+		return
+	}
+	svc := privacyService(c)
+	if svc == nil {
+		return
+	}
+	filename, content, err := svc.Export(c.Request.Context(), id, GetUserIDFromCtx(c, 1))
+	if err != nil {
+		privacyError(c, err, "download privacy export")
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Data(http.StatusOK, "application/zip", content)
+}
@@ -0,0 +1,48 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrivacyAPI_InvalidRequests(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.POST("/api/v1/admin/privacy/requests", HandleCreatePrivacyRequestAPI)
+	router.GET("/api/v1/admin/privacy/requests/:id", HandleGetPrivacyRequestAPI)
+	router.POST("/api/v1/admin/privacy/requests/:id/approve", HandleApprovePrivacyRequestAPI)
+	router.POST("/api/v1/admin/privacy/requests/:id/reject", HandleRejectPrivacyRequestAPI)
+	router.GET("/api/v1/admin/privacy/requests/:id/export", HandleDownloadPrivacyExportAPI)
+
+	for _, tc := range []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		want   string
+	}{
+		{"no subject", http.MethodPost, "/api/v1/admin/privacy/requests", `{"kind": "export"}`, "Invalid privacy request"},
+		{"no kind", http.MethodPost, "/api/v1/admin/privacy/requests", `{"subject": "jane"}`, "Invalid privacy request"},
+		{"bad id", http.MethodGet, "/api/v1/admin/privacy/requests/abc", ``, "Invalid privacy request ID"},
+		{"approve zero id", http.MethodPost, "/api/v1/admin/privacy/requests/0/approve", ``, "Invalid privacy request ID"},
+		{"approve bad body", http.MethodPost, "/api/v1/admin/privacy/requests/1/approve", `{"comment": 5}`, "Invalid decision"},
+		{"reject bad id", http.MethodPost, "/api/v1/admin/privacy/requests/-1/reject", ``, "Invalid privacy request ID"},
+		{"export bad id", http.MethodGet, "/api/v1/admin/privacy/requests/x/export", ``, "Invalid privacy request ID"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Contains(t, w.Body.String(), tc.want)
+		})
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/gin-gonic/gin"
 
 	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/services"
 )
 
 // HandleAPIQueueGet handles GET /api/queues/:id.
@@ -262,5 +263,6 @@ func HandleAPIQueueStatus(c *gin.Context) {
 		return
 	}
 
+	services.InvalidateQueuePermissions()
 	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Queue status updated"})
 }
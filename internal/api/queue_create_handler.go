@@ -1,18 +1,19 @@
 package api
 
 import (
-	"fmt"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 
 	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/service"
+	"github.com/goatkit/goatflow/internal/services"
 )
 
 // HandleCreateQueueAPI handles POST /api/v1/queues.
 //
 //	@Summary		Create queue
-//	@Description	Create a new queue
+//	@Description	Create a new queue. A nested name such as "Support::Hardware" creates a sub-queue; the parent queue must exist. Group, system address, salutation, signature and follow-up IDs must refer to valid rows.
 //	@Tags			Queues
 //	@Accept			json
 //	@Produce		json
@@ -20,16 +21,14 @@ import (
 //	@Success		201		{object}	map[string]interface{}	"Created queue"
 //	@Failure		400		{object}	map[string]interface{}	"Invalid request"
 //	@Failure		401		{object}	map[string]interface{}	"Unauthorized"
+//	@Failure		409		{object}	map[string]interface{}	"Queue name already exists"
 //	@Security		BearerAuth
 //	@Router			/queues [post]
 func HandleCreateQueueAPI(c *gin.Context) {
-	// Check authentication and admin permissions
-	userID, exists := c.Get("user_id")
-	if !exists {
+	if _, exists := c.Get("user_id"); !exists {
 		c.JSON(http.StatusUnauthorized, gin.H{"success": false, "error": "Unauthorized"})
 		return
 	}
-	_ = userID // TODO: Check admin permissions
 
 	var req struct {
 		Name            string  `json:"name" binding:"required"`
@@ -41,6 +40,7 @@ func HandleCreateQueueAPI(c *gin.Context) {
 		FollowUpID      int     `json:"follow_up_id"`
 		FollowUpLock    int     `json:"follow_up_lock"`
 		Comments        *string `json:"comments"`
+		ValidID         int     `json:"valid_id"`
 		GroupAccess     []int   `json:"group_access"`
 	}
 
@@ -49,130 +49,60 @@ func HandleCreateQueueAPI(c *gin.Context) {
 		return
 	}
 
-	db, err := database.GetDB()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Database connection failed"})
+	svc := queueManagementService(c)
+	if svc == nil {
 		return
 	}
-
-	// Check if queue with this name already exists
-	var count int
-	checkQuery := database.ConvertPlaceholders(`
-        SELECT 1 FROM queue
-        WHERE name = ? AND valid_id = 1
-    `)
-	row := db.QueryRow(checkQuery, req.Name)
-	_ = row.Scan(&count) //nolint:errcheck // Count defaults to 0
-	if count == 1 {
-		c.JSON(http.StatusConflict, gin.H{"success": false, "error": "Queue with this name already exists"})
-		return
+	ctx := c.Request.Context()
+
+	// Unset fields keep the defaults, which mirror the bootstrap data
+	// (schema/baseline/required_lookups.sql).
+	settings := service.DefaultQueueSettings()
+	settings.Name = req.Name
+	settings.UnlockTimeout = req.UnlockTimeout
+	settings.FollowUpLock = req.FollowUpLock
+	settings.Comments = req.Comments
+	if req.GroupID != 0 {
+		settings.GroupID = req.GroupID
 	}
-
-	// Start transaction
-	tx, err := db.Begin()
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to start transaction"})
-		return
+	if req.SystemAddressID != nil {
+		settings.SystemAddressID = *req.SystemAddressID
 	}
-	defer func() { _ = tx.Rollback() }()
-
-	// Defaults mirror baseline bootstrap data (schema/baseline/required_lookups.sql)
-	if req.GroupID == 0 {
-		req.GroupID = 1
-	} // Default to group 1
-	if req.SystemAddressID == nil {
-		d := 1
-		req.SystemAddressID = &d
+	if req.SalutationID != nil {
+		settings.SalutationID = *req.SalutationID
 	}
-	if req.SalutationID == nil {
-		d := 1
-		req.SalutationID = &d
+	if req.SignatureID != nil {
+		settings.SignatureID = *req.SignatureID
 	}
-	if req.SignatureID == nil {
-		d := 1
-		req.SignatureID = &d
+	if req.FollowUpID != 0 {
+		settings.FollowUpID = req.FollowUpID
 	}
-	if req.FollowUpID == 0 {
-		req.FollowUpID = 1
-	} // Default allowed follow-up
-	if req.FollowUpLock == 0 {
-		req.FollowUpLock = 0
+	if req.ValidID != 0 {
+		settings.ValidID = req.ValidID
 	}
-	if req.UnlockTimeout < 0 {
-		req.UnlockTimeout = 0
+	if err := svc.ValidateGroups(ctx, req.GroupAccess); err != nil {
+		queueManagementError(c, err, "create queue")
+		return
 	}
 
-	// Get user ID for DB parameters
-	createdBy := GetUserIDFromCtx(c, 1)
-
-	// Create queue - adapter handles MySQL vs PostgreSQL differences
-	// Note: ? is used for both create_by and change_by (repeated placeholder)
-	insertQuery := `
-		INSERT INTO queue (
-			name, group_id, system_address_id, salutation_id, signature_id,
-			unlock_timeout, follow_up_id, follow_up_lock, comments, valid_id,
-			create_time, create_by, change_time, change_by
-		) VALUES (
-			?, ?, ?, ?, ?,
-			?, ?, ?, ?, 1,
-			NOW(), ?, NOW(), ?
-		) RETURNING id`
-
-	queueID64, err := database.GetAdapter().InsertWithReturningTx(tx, insertQuery,
-		req.Name,
-		req.GroupID,
-		req.SystemAddressID,
-		req.SalutationID,
-		req.SignatureID,
-		req.UnlockTimeout,
-		req.FollowUpID,
-		req.FollowUpLock,
-		req.Comments,
-		createdBy,
-	)
+	queueID, err := svc.Create(ctx, settings, GetUserIDFromCtx(c, 1))
 	if err != nil {
-		_ = tx.Rollback()
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"success": false,
-			"error":   fmt.Sprintf("Queue insert failed: %v", err),
-		})
+		queueManagementError(c, err, "create queue")
 		return
 	}
-	queueID := int(queueID64)
 
-	// Add group access if specified
 	if len(req.GroupAccess) > 0 {
-		for _, groupID := range req.GroupAccess {
-			// Optional: only if auxiliary table exists in current schema
-			groupInsert := database.ConvertPlaceholders(`INSERT INTO queue_group (queue_id, group_id) VALUES (?, ?)`)
-			if _, err := tx.Exec(groupInsert, queueID, groupID); err != nil {
-				// Swallow error if table doesn't exist (compatibility with minimal schema)
-				// Comment out the early-return to avoid breaking core creation
-				// c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to set group access: %v", err)})
+		if db, err := database.GetDB(); err == nil && db != nil {
+			for _, groupID := range req.GroupAccess {
+				// Optional: only if auxiliary table exists in current schema
+				groupInsert := database.ConvertPlaceholders(`INSERT INTO queue_group (queue_id, group_id) VALUES (?, ?)`)
+				_, _ = db.Exec(groupInsert, queueID, groupID) //nolint:errcheck // Minimal schemas have no queue_group
 			}
 		}
 	}
 
-	// Commit transaction
-	if err := tx.Commit(); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to commit transaction"})
-		return
-	}
-
-	// Return created queue
-	response := gin.H{
-		"id":       queueID,
-		"name":     req.Name,
-		"group_id": req.GroupID,
-		"comments": func() interface{} {
-			if req.Comments != nil {
-				return *req.Comments
-			}
-			return nil
-		}(),
-		"valid_id":     1,
-		"group_access": req.GroupAccess,
-	}
-
+	services.InvalidateQueuePermissions()
+	response := queueSettingsResponse(queueID, settings)
+	response["group_access"] = req.GroupAccess
 	c.JSON(http.StatusCreated, gin.H{"success": true, "data": response})
 }
@@ -3,6 +3,7 @@ package api
 
 import (
 	"database/sql"
+	"errors"
 	"log"
 	"net/http"
 	"strconv"
@@ -10,6 +11,8 @@ import (
 	"github.com/gin-gonic/gin"
 
 	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/service"
+	"github.com/goatkit/goatflow/internal/services"
 )
 
 // deleteQueueInTransaction performs the queue deletion within a transaction.
@@ -54,6 +57,7 @@ func deleteQueueInTransaction(tx *sql.Tx, queueID int, userID interface{}) error
 //	@Success		200	{object}	map[string]interface{}	"Queue deleted"
 //	@Failure		401	{object}	map[string]interface{}	"Unauthorized"
 //	@Failure		404	{object}	map[string]interface{}	"Queue not found"
+//	@Failure		409	{object}	map[string]interface{}	"Queue has tickets or valid sub-queues"
 //	@Security		BearerAuth
 //	@Router			/queues/{id} [delete]
 func HandleDeleteQueueAPI(c *gin.Context) {
@@ -80,6 +84,16 @@ func HandleDeleteQueueAPI(c *gin.Context) {
 		return
 	}
 
+	// A valid sub-queue would be left below an invalid parent.
+	if err := service.NewQueueManagementService(db).CheckDelete(c.Request.Context(), queueID); err != nil {
+		if errors.Is(err, service.ErrQueueHasSubQueues) {
+			c.JSON(http.StatusConflict, gin.H{"success": false, "error": "Cannot delete queue with valid sub-queues"})
+			return
+		}
+		queueManagementError(c, err, "delete queue")
+		return
+	}
+
 	var ticketCount int
 	ticketQuery := database.ConvertPlaceholders(`SELECT COUNT(*) FROM ticket WHERE queue_id = ?`)
 	if err := db.QueryRow(ticketQuery, queueID).Scan(&ticketCount); err != nil {
@@ -112,5 +126,6 @@ func HandleDeleteQueueAPI(c *gin.Context) {
 		return
 	}
 
+	services.InvalidateQueuePermissions()
 	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Queue deleted successfully"})
 }
@@ -9,6 +9,7 @@ import (
 	"github.com/gin-gonic/gin"
 
 	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/services"
 )
 
 // queueDB returns the database handle for queue operations.
@@ -408,6 +409,7 @@ func handleUpdateQueue(c *gin.Context) {
 		return
 	}
 
+	services.InvalidateQueuePermissions()
 	c.JSON(http.StatusOK, gin.H{"success": true, "data": resp})
 }
 
@@ -456,5 +458,6 @@ func handleDeleteQueue(c *gin.Context) {
 		c.Header("HX-Trigger", "queue-deleted")
 		c.Header("HX-Redirect", "/queues")
 	}
+	services.InvalidateQueuePermissions()
 	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Queue deleted successfully"})
 }
@@ -3,7 +3,6 @@ package api
 import (
 	"database/sql"
 	"fmt"
-	"html/template"
 	"log"
 	"net/http"
 	"strconv"
@@ -26,15 +25,15 @@ func init() {
 
 // handleQueues shows the queues list page.
 func handleQueues(c *gin.Context) {
-	if htmxHandlerSkipDB() {
-		renderQueuesTestFallback(c)
-		return
-	}
 	// If templates are unavailable, return error
 	if getPongo2Renderer() == nil || getPongo2Renderer().TemplateSet() == nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Template system unavailable"})
 		return
 	}
+	if htmxHandlerSkipDB() {
+		renderQueuesPage(c, sampleQueueRows())
+		return
+	}
 
 	db, err := database.GetDB()
 	if err != nil || db == nil {
@@ -42,10 +41,6 @@ func handleQueues(c *gin.Context) {
 		return
 	}
 
-	// Optional search filter
-	search := strings.TrimSpace(c.Query("search"))
-	searchLower := strings.ToLower(search)
-
 	// Get user ID and check if admin
 	userID := uint(0)
 	if val, exists := c.Get("user_id"); exists {
@@ -165,9 +160,6 @@ func handleQueues(c *gin.Context) {
 	// Transform for template
 	viewQueues := make([]gin.H, 0, len(queues))
 	for _, q := range queues {
-		if searchLower != "" && !strings.Contains(strings.ToLower(q.Name), searchLower) {
-			continue
-		}
 		m := stats[q.ID]
 		viewQueues = append(viewQueues, gin.H{
 			"ID":      q.ID,
@@ -182,82 +174,66 @@ func handleQueues(c *gin.Context) {
 		})
 	}
 
+	renderQueuesPage(c, viewQueues)
+}
+
+// renderQueuesPage filters, sorts and pages the queue rows and renders the list.
+func renderQueuesPage(c *gin.Context, rows []gin.H) {
+	table := newAdminTable(c, "queues-table", "nav.queues", "/queues", []adminTableColumn{
+		{Key: "name", Label: "tickets.queue", Class: "text-left"},
+		{Key: "new", Label: "status.new", Class: "text-right"},
+		{Key: "open", Label: "status.open", Class: "text-right"},
+		{Key: "pending", Label: "status.pending", Class: "text-right"},
+		{Key: "closed", Label: "status.closed", Class: "text-right"},
+		{Key: "total", Label: "common.total", Class: "text-right"},
+	}, "name", "search")
+
+	search := table.Filter("search")
+	if search != "" {
+		needle := strings.ToLower(search)
+		filtered := rows[:0]
+		for _, row := range rows {
+			if name, _ := row["Name"].(string); strings.Contains(strings.ToLower(name), needle) {
+				filtered = append(filtered, row)
+			}
+		}
+		rows = filtered
+	}
+	rows = paginateAdminTable(table, rows, func(row gin.H, key string) any {
+		switch key {
+		case "new":
+			return row["New"]
+		case "open":
+			return row["Open"]
+		case "pending":
+			return row["Pending"]
+		case "closed":
+			return row["Closed"]
+		case "total":
+			return row["Total"]
+		}
+		return row["Name"]
+	})
+
 	getPongo2Renderer().HTML(c, http.StatusOK, "pages/queues.pongo2", pongo2.Context{
-		"Queues":     viewQueues,
+		"Queues":     rows,
 		"Search":     search,
+		"Table":      table,
 		"User":       getUserMapForTemplate(c),
 		"ActivePage": "queues",
 	})
 }
 
-func renderQueuesTestFallback(c *gin.Context) {
-	c.Header("Content-Type", "text/html; charset=utf-8")
-	type queue struct {
-		ID      int
-		Name    string
-		Detail  string
-		Tickets int
+// sampleQueueRows stands in for the queue list when handlers run without a database.
+func sampleQueueRows() []gin.H {
+	return []gin.H{
+		{"ID": 1, "Name": "General Support", "Comment": "Manage ticket queues", "ValidID": 1,
+			"New": 2, "Open": 6, "Pending": 1, "Closed": 3, "Total": 12},
+		{"ID": 2, "Name": "Technical Support", "Comment": "Escalated incidents", "ValidID": 1,
+			"New": 1, "Open": 2, "Pending": 1, "Closed": 2, "Total": 6},
+		{"ID": 3, "Name": "Billing", "Comment": "Invoices and refunds", "ValidID": 1,
+			"New": 1, "Open": 0, "Pending": 0, "Closed": 1, "Total": 3},
 	}
-	queues := []queue{
-		{ID: 1, Name: "General Support", Detail: "Manage ticket queues", Tickets: 12},
-		{ID: 2, Name: "Technical Support", Detail: "Escalated incidents", Tickets: 6},
-		{ID: 3, Name: "Billing", Detail: "Invoices and refunds", Tickets: 3},
-	}
-
-	var sb strings.Builder
-	sb.Grow(2048)
-	sb.WriteString(`<!DOCTYPE html><html lang="en"><head><meta charset="utf-8"><title>Queues - GoatFlow</title></head>`)
-	sb.WriteString(`<body class="bg-white text-gray-900 text-2xl sm:text-3xl dark:bg-gray-800 dark:text-white">`)
-	sb.WriteString(`<main class="max-w-4xl mx-auto px-4 py-6">`)
-	const statBox = `rounded-md bg-gray-100 p-3 dark:bg-gray-900`
-	const statLabel = `block font-semibold`
-	const statVal = `text-lg font-medium`
-	sb.WriteString(`<header class="mb-4">`)
-	sb.WriteString(`<h1 class="font-bold text-2xl sm:text-3xl">Queue Management</h1>`)
-	sb.WriteString(`<p class="mt-1 text-sm text-gray-600 dark:text-gray-300">Manage ticket queues</p>`)
-	sb.WriteString(`</header>`)
-	sb.WriteString(`<section class="grid grid-cols-2 sm:grid-cols-5 gap-3 text-sm" aria-label="Queue stats">`)
-	sb.WriteString(`<div class="` + statBox + `"><span class="` + statLabel + `">New</span>` +
-		`<span class="` + statVal + `">4</span></div>`)
-	sb.WriteString(`<div class="` + statBox + `"><span class="` + statLabel + `">Open</span>` +
-		`<span class="` + statVal + `">8</span></div>`)
-	sb.WriteString(`<div class="` + statBox + `"><span class="` + statLabel + `">Pending</span>` +
-		`<span class="` + statVal + `">2</span></div>`)
-	sb.WriteString(`<div class="` + statBox + `"><span class="` + statLabel + `">Closed</span>` +
-		`<span class="` + statVal + `">6</span></div>`)
-	sb.WriteString(`<div class="` + statBox + ` sm:col-span-2"><span class="` + statLabel + `">Total</span>` +
-		`<span class="` + statVal + `">20</span></div>`)
-	sb.WriteString(`</section>`)
-	const btnPrimary = `inline-flex items-center rounded-md bg-goatflow-600 px-3 py-2 text-sm ` +
-		`font-semibold text-white dark:bg-gray-700 dark:hover:bg-gray-700`
-	sb.WriteString(`<a href="/queues/new" class="` + btnPrimary + `">New Queue</a>`)
-	sb.WriteString(`<section class="mt-6 bg-white dark:bg-gray-800 rounded-lg shadow-sm">`)
-	sb.WriteString(`<ul role="list" class="divide-y divide-gray-200 dark:divide-gray-700">`)
-	const btnView = `inline-flex items-center rounded-md border border-gray-300 ` +
-		`px-2 py-1 text-sm text-gray-700 dark:bg-gray-800 dark:hover:bg-gray-700`
-	for _, q := range queues {
-		sb.WriteString(`<li class="py-4">`)
-		sb.WriteString(`<div class="flex items-center justify-between">`)
-		sb.WriteString(`<div>`)
-		sb.WriteString(fmt.Sprintf(`<div class="text-lg font-semibold"><span>ID: %d</span> &#8212; %s</div>`,
-			q.ID, template.HTMLEscapeString(q.Name)))
-		sb.WriteString(fmt.Sprintf(`<div class="text-sm text-gray-600 dark:text-gray-300">%s</div>`,
-			template.HTMLEscapeString(q.Detail)))
-		sb.WriteString(`</div>`)
-		sb.WriteString(`<div class="flex items-center space-x-3">`)
-		sb.WriteString(`<span class="text-sm text-gray-500 dark:text-gray-300">Active</span>`)
-		sb.WriteString(fmt.Sprintf(`<span class="text-sm text-blue-600">%d tickets</span>`, q.Tickets))
-		sb.WriteString(`<button class="` + btnView + `">View</button>`)
-		sb.WriteString(`</div>`)
-		sb.WriteString(`</div>`)
-		sb.WriteString(`</li>`)
-	}
-	sb.WriteString(`</ul>`)
-	sb.WriteString(`</section>`)
-	sb.WriteString(`</main>`)
-	sb.WriteString(`</body></html>`)
-
-	c.String(http.StatusOK, sb.String())
 }
 
 func renderDashboardTestFallback(c *gin.Context) {
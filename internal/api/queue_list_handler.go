@@ -15,13 +15,17 @@ import (
 // HandleListQueuesAPI handles GET /api/v1/queues.
 //
 //	@Summary		List queues
-//	@Description	Retrieve all queues the user has access to (RBAC filtered)
+//	@Description	Retrieve the queues the user has access to (RBAC filtered). Without page, per_page or cursor all queues are returned.
 //	@Tags			Queues
 //	@Accept			json
 //	@Produce		json
 //	@Param			page		query		int		false	"Page number"		default(1)
-//	@Param			per_page	query		int		false	"Items per page"	default(20)
+//	@Param			per_page	query		int		false	"Items per page (max 100)"	default(20)
+//	@Param			cursor		query		string	false	"Cursor from pagination.next_cursor; pass it empty to start cursor pagination"
+//	@Param			fields		query		string	false	"Comma-separated fields to return (id is always returned)"
 //	@Success		200			{object}	map[string]interface{}	"List of queues"
+//	@Header			200			{string}	Link					"RFC 5988 links when the list is paginated"
+//	@Failure		400			{object}	map[string]interface{}	"Invalid cursor or fields parameter"
 //	@Failure		401			{object}	map[string]interface{}	"Unauthorized"
 //	@Security		BearerAuth
 //	@Router			/queues [get]
@@ -61,6 +65,27 @@ func HandleListQueuesAPI(c *gin.Context) {
 	validFilter := c.Query("valid") // "1" for valid only, "2" for invalid only, "" for all
 	includeStats := c.Query("include_stats") == "true"
 
+	fields, err := parseListNames(c, "fields", queueListFields)
+	if err != nil {
+		listParamError(c, err)
+		return
+	}
+	cursorMode, cursor, err := listCursorParam(c, "name", "asc")
+	if err != nil {
+		listParamError(c, err)
+		return
+	}
+
+	// The queue list predates pagination; it is only paginated on request.
+	paged := cursorMode || c.Query("page") != "" || c.Query("per_page") != ""
+	page, perPage := 1, 20
+	if p, err := strconv.Atoi(c.Query("page")); err == nil && p > 0 {
+		page = p
+	}
+	if pp, err := strconv.Atoi(c.Query("per_page")); err == nil && pp > 0 {
+		perPage = min(pp, 100)
+	}
+
 	// Get database connection
 	db, err := database.GetDB()
 	if err != nil || db == nil {
@@ -136,11 +161,42 @@ func HandleListQueuesAPI(c *gin.Context) {
 		}
 	}
 
+	var total int
+	if paged && !cursorMode {
+		countQuery := "SELECT COUNT(*) FROM queue q WHERE " + strings.Join(conditions, " AND ")
+		if err := db.QueryRow(database.ConvertPlaceholders(countQuery), args...).Scan(&total); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"error":   "Failed to count queues",
+			})
+			return
+		}
+	}
+
+	if cursor != nil {
+		cond, condArgs, err := queueListSort.keyset(cursor, "q.id", "asc")
+		if err != nil {
+			listParamError(c, err)
+			return
+		}
+		conditions = append(conditions, cond)
+		args = append(args, condArgs...)
+	}
+
 	if len(conditions) > 0 {
 		query += " WHERE " + strings.Join(conditions, " AND ")
 	}
 
-	query += " ORDER BY q.name"
+	query += " ORDER BY q.name, q.id"
+	switch {
+	case cursorMode:
+		// One extra row tells whether there is a next page.
+		query += " LIMIT ?"
+		args = append(args, perPage+1)
+	case paged:
+		query += " LIMIT ? OFFSET ?"
+		args = append(args, perPage, (page-1)*perPage)
+	}
 	query = database.ConvertPlaceholders(query)
 
 	// Execute query
@@ -252,6 +308,10 @@ func HandleListQueuesAPI(c *gin.Context) {
 		}
 
 		// Get groups that have access to this queue
+		if fields != nil && !fields["groups"] {
+			queues = append(queues, queueMap)
+			continue
+		}
 		groupQuery := database.ConvertPlaceholders(`
 			SELECT DISTINCT g.id, g.name
 			FROM groups g
@@ -284,6 +344,12 @@ func HandleListQueuesAPI(c *gin.Context) {
 	}
 	_ = rows.Err() //nolint:errcheck // Check for iteration errors
 
+	nextCursor := ""
+	if cursorMode && len(queues) > perPage {
+		queues = queues[:perPage]
+		nextCursor = queueListSort.cursorAfter("name", "asc", queues[perPage-1])
+	}
+
 	// Apply ticket attribute relations filtering if requested
 	filterAttr := c.Query("filter_attribute")
 	filterValue := c.Query("filter_value")
@@ -301,8 +367,52 @@ func HandleListQueuesAPI(c *gin.Context) {
 		}
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"success": true,
-		"data":    queues,
-	})
+	for i := range queues {
+		queues[i] = selectListFields(queues[i], fields, nil)
+	}
+
+	switch {
+	case cursorMode:
+		setCursorLinkHeader(c, nextCursor)
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"data":    queues,
+			"pagination": gin.H{
+				"per_page":    perPage,
+				"next_cursor": nextCursor,
+				"has_next":    nextCursor != "",
+			},
+		})
+	case paged:
+		totalPages := (total + perPage - 1) / perPage
+		setPageLinkHeader(c, page, totalPages)
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"data":    queues,
+			"pagination": gin.H{
+				"page":        page,
+				"per_page":    perPage,
+				"total":       total,
+				"total_pages": totalPages,
+				"has_next":    page < totalPages,
+				"has_prev":    page > 1,
+			},
+		})
+	default:
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"data":    queues,
+		})
+	}
+}
+
+// queueListSort orders the queue list by name; queue names are unique, the
+// ID only guards against duplicates in imported data.
+var queueListSort = listSort{Column: "q.name", Field: "name", Kind: listSortString}
+
+// queueListFields are the fields accepted by fields= on the queue list.
+var queueListFields = []string{
+	"name", "group_id", "group_name", "system_address_id", "unlock_timeout", "follow_up_id",
+	"follow_up_lock", "comment", "valid_id", "valid", "create_time", "change_time",
+	"ticket_count", "open_tickets", "closed_tickets", "pending_tickets", "groups",
 }
@@ -0,0 +1,143 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/service"
+	"github.com/goatkit/goatflow/internal/services"
+)
+
+// queueMoveRequest is the JSON body of a queue move. A null or zero
+// parent_id moves the queue to the top level.
+type queueMoveRequest struct {
+	ParentID *int `json:"parent_id"`
+}
+
+// queueManagementService creates a queue management service, writing 503
+// when the database is unavailable.
+func queueManagementService(c *gin.Context) *service.QueueManagementService {
+	db, err := database.GetDB()
+	if err != nil || db == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"success": false, "error": "Database unavailable"})
+		return nil
+	}
+	return service.NewQueueManagementService(db)
+}
+
+// queueManagementError maps QueueManagementService errors to responses.
+func queueManagementError(c *gin.Context, err error, action string) {
+	switch {
+	case errors.Is(err, service.ErrQueueNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Queue not found"})
+	case errors.Is(err, service.ErrQueueNameExists),
+		errors.Is(err, service.ErrQueueCycle),
+		errors.Is(err, service.ErrQueueHasSubQueues):
+		c.JSON(http.StatusConflict, gin.H{"success": false, "error": err.Error()})
+	case errors.Is(err, service.ErrQueueInvalid),
+		errors.Is(err, service.ErrQueueParentNotFound):
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+	default:
+		log.Printf("queue api: %s failed: %v", action, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to " + action})
+	}
+}
+
+// queueSettingsResponse is the JSON shape of a stored queue.
+func queueSettingsResponse(id int, s *models.QueueSettings) gin.H {
+	resp := gin.H{
+		"id":                id,
+		"name":              s.Name,
+		"group_id":          s.GroupID,
+		"system_address_id": s.SystemAddressID,
+		"salutation_id":     s.SalutationID,
+		"signature_id":      s.SignatureID,
+		"unlock_timeout":    s.UnlockTimeout,
+		"follow_up_id":      s.FollowUpID,
+		"follow_up_lock":    s.FollowUpLock,
+		"valid_id":          s.ValidID,
+		"comments":          nil,
+	}
+	if s.Comments != nil {
+		resp["comments"] = *s.Comments
+	}
+	return resp
+}
+
+// HandleQueueTreeAPI handles GET /api/v1/queues/tree.
+//
+//	@Summary		Get queue tree
+//	@Description	Returns the top-level queues with their sub-queues nested in children. Queues nest by name, as in "Support::Hardware".
+//	@Tags			Queues
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}	"Queue tree"
+//	@Security		BearerAuth
+//	@Router			/queues/tree [get]
+func HandleQueueTreeAPI(c *gin.Context) {
+	svc := queueManagementService(c)
+	if svc == nil {
+		return
+	}
+	roots, err := svc.Tree(c.Request.Context())
+	if err != nil {
+		queueManagementError(c, err, "load queue tree")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": roots})
+}
+
+// HandleMoveQueueAPI handles POST /api/v1/queues/:id/move.
+//
+//	@Summary		Move queue
+//	@Description	Places the queue, with its sub-queues, below another queue or at the top level. The queue keeps its short name; the sub-queues are renamed with it.
+//	@Tags			Queues
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int						true	"Queue ID"
+//	@Param			move	body		object					true	"Target (parent_id; null or 0 for the top level)"
+//	@Success		200		{object}	map[string]interface{}	"Moved queue"
+//	@Failure		400		{object}	map[string]interface{}	"Invalid request"
+//	@Failure		404		{object}	map[string]interface{}	"Queue not found"
+//	@Failure		409		{object}	map[string]interface{}	"Name taken or move below its own subtree"
+//	@Security		BearerAuth
+//	@Router			/queues/{id}/move [post]
+func HandleMoveQueueAPI(c *gin.Context) {
+	queueID, err := strconv.Atoi(c.Param("id"))
+	if err != nil || queueID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid queue ID"})
+		return
+	}
+	var req queueMoveRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid request: " + err.Error()})
+		return
+	}
+	parentID := 0
+	if req.ParentID != nil {
+		parentID = *req.ParentID
+	}
+	if parentID < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "parent_id must not be negative"})
+		return
+	}
+	svc := queueManagementService(c)
+	if svc == nil {
+		return
+	}
+	settings, renamed, err := svc.Move(c.Request.Context(), queueID, parentID, GetUserIDFromCtx(c, 1))
+	if err != nil {
+		queueManagementError(c, err, "move queue")
+		return
+	}
+	services.InvalidateQueuePermissions()
+	resp := queueSettingsResponse(queueID, settings)
+	resp["parent_id"] = parentID
+	resp["renamed_sub_queues"] = renamed
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": resp})
+}
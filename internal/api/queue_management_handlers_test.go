@@ -0,0 +1,64 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/goatkit/goatflow/internal/service"
+)
+
+func TestMoveQueueAPI_InvalidRequests(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.POST("/api/v1/queues/:id/move", HandleMoveQueueAPI)
+
+	for _, tc := range []struct {
+		name string
+		path string
+		body string
+		want string
+	}{
+		{"bad id", "/api/v1/queues/abc/move", `{"parent_id": 1}`, "Invalid queue ID"},
+		{"not JSON", "/api/v1/queues/4/move", `parent=1`, "Invalid request"},
+		{"negative parent", "/api/v1/queues/4/move", `{"parent_id": -2}`, "must not be negative"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tc.path, strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Contains(t, w.Body.String(), tc.want)
+		})
+	}
+}
+
+func TestQueueManagementError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	for _, tc := range []struct {
+		err  error
+		code int
+	}{
+		{service.ErrQueueNotFound, http.StatusNotFound},
+		{fmt.Errorf("%w: Support::Hardware", service.ErrQueueNameExists), http.StatusConflict},
+		{service.ErrQueueCycle, http.StatusConflict},
+		{service.ErrQueueHasSubQueues, http.StatusConflict},
+		{fmt.Errorf("%w: Billing", service.ErrQueueParentNotFound), http.StatusBadRequest},
+		{fmt.Errorf("%w: group_id 9 does not exist or is not valid", service.ErrQueueInvalid), http.StatusBadRequest},
+		{fmt.Errorf("connection reset"), http.StatusInternalServerError},
+	} {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		queueManagementError(c, tc.err, "update queue")
+		assert.Equal(t, tc.code, w.Code, tc.err.Error())
+	}
+}
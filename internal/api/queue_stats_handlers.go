@@ -0,0 +1,95 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/service"
+)
+
+// HandleListQueueStatsAPI handles GET /api/v1/stats/queues.
+//
+//	@Summary		Queue statistics
+//	@Description	Returns open and pending counts, created and closed tickets, and average first response and resolution times per queue, with a daily trend. Statistics are aggregated nightly and cover completed days up to yesterday.
+//	@Tags			Statistics
+//	@Produce		json
+//	@Param			window		query		string	false	"Number of days such as 7d or 30d, up to 365d (default 7d)"
+//	@Param			queue_id	query		int		false	"Only this queue"
+//	@Success		200			{object}	map[string]interface{}	"Queue statistics"
+//	@Failure		400			{object}	map[string]interface{}	"Invalid window or queue"
+//	@Failure		403			{object}	map[string]interface{}	"No access to the queue"
+//	@Security		BearerAuth
+//	@Router			/stats/queues [get]
+func HandleListQueueStatsAPI(c *gin.Context) {
+	if attachmentRole(c) == service.AttachmentRoleCustomer {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": "Queue statistics are only available to agents"})
+		return
+	}
+	userID := uint(GetUserIDFromCtx(c, 0))
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"success": false, "error": "Authentication required"})
+		return
+	}
+	db, err := database.GetDB()
+	if err != nil || db == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"success": false, "error": "Database unavailable"})
+		return
+	}
+
+	var queueID int
+	if raw := c.Query("queue_id"); raw != "" {
+		queueID, err = strconv.Atoi(raw)
+		if err != nil || queueID <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid queue ID"})
+			return
+		}
+	}
+
+	// Agents only see the queues they can read; nil means every queue
+	ctx := c.Request.Context()
+	access := service.NewQueueAccessService(db)
+	admin, err := access.IsAdmin(ctx, userID)
+	if err != nil {
+		log.Printf("queue stats api: check admin: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to check permissions"})
+		return
+	}
+	var queueIDs []int
+	if !admin {
+		accessible, err := access.GetAccessibleQueueIDs(ctx, userID, "ro")
+		if err != nil {
+			log.Printf("queue stats api: accessible queues: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to check permissions"})
+			return
+		}
+		queueIDs = make([]int, 0, len(accessible))
+		for _, id := range accessible {
+			queueIDs = append(queueIDs, int(id))
+		}
+	}
+	if queueID > 0 {
+		if queueIDs != nil && !slices.Contains(queueIDs, queueID) {
+			c.JSON(http.StatusForbidden, gin.H{"success": false, "error": "You do not have permission to access this queue"})
+			return
+		}
+		queueIDs = []int{queueID}
+	}
+
+	report, err := service.NewQueueStatsService(db).Stats(ctx, c.Query("window"), queueIDs)
+	if errors.Is(err, service.ErrStatsWindow) {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	if err != nil {
+		log.Printf("queue stats api: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to load queue statistics"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": report})
+}
@@ -0,0 +1,92 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/repository"
+	"github.com/goatkit/goatflow/internal/testutil"
+)
+
+func TestHandleListQueueStatsAPI(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := testutil.UseMigratedDB(t)
+	for _, stmt := range []string{
+		`INSERT INTO groups (id, name, valid_id, create_time, create_by, change_time, change_by)
+			VALUES (11, 'queue-stats', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+		`UPDATE queue SET group_id = CASE WHEN id = 2 THEN 11 ELSE (SELECT id FROM groups WHERE name = 'admin') END`,
+		`INSERT INTO group_user (user_id, group_id, permission_key, create_time, create_by, change_time, change_by)
+			SELECT 1, id, 'rw', CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1 FROM groups WHERE name = 'admin'`,
+		`INSERT INTO group_user (user_id, group_id, permission_key, create_time, create_by, change_time, change_by)
+			VALUES (2, 11, 'ro', CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+	} {
+		_, err := db.Exec(stmt)
+		require.NoError(t, err, stmt)
+	}
+	now := time.Now().UTC()
+	yesterday := time.Date(now.Year(), now.Month(), now.Day()-1, 0, 0, 0, 0, time.UTC)
+	open := 3
+	require.NoError(t, repository.NewQueueStatsRepository(db).SaveDay(context.Background(), yesterday, []models.QueueStatsDay{
+		{QueueID: 1, OpenCount: &open, CreatedCount: 4},
+		{QueueID: 2, OpenCount: &open, CreatedCount: 2, FirstResponseCount: 2, FirstResponseSeconds: 600},
+	}))
+
+	get := func(path string, userID int, customer bool) (int, map[string]interface{}) {
+		router := gin.New()
+		router.Use(func(c *gin.Context) {
+			c.Set("user_id", userID)
+			c.Set("is_customer", customer)
+			c.Next()
+		})
+		router.GET("/api/v1/stats/queues", HandleListQueueStatsAPI)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		var body map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		return w.Code, body
+	}
+	queueIDs := func(body map[string]interface{}) []float64 {
+		var ids []float64
+		for _, q := range body["data"].(map[string]interface{})["queues"].([]interface{}) {
+			ids = append(ids, q.(map[string]interface{})["queue_id"].(float64))
+		}
+		return ids
+	}
+
+	code, _ := get("/api/v1/stats/queues", 5, true)
+	assert.Equal(t, http.StatusForbidden, code, "customers are refused")
+	code, _ = get("/api/v1/stats/queues", 0, false)
+	assert.Equal(t, http.StatusUnauthorized, code)
+	code, body := get("/api/v1/stats/queues?window=1w", 1, false)
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Contains(t, body["error"], "window")
+	code, _ = get("/api/v1/stats/queues?queue_id=raw", 1, false)
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	code, body = get("/api/v1/stats/queues?window=30d", 1, false)
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []float64{1, 2}, queueIDs(body), "admins see every queue")
+	assert.Equal(t, yesterday.Format("2006-01-02"), body["data"].(map[string]interface{})["as_of"])
+
+	code, body = get("/api/v1/stats/queues", 2, false)
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []float64{2}, queueIDs(body), "agents see the queues they can read")
+	queue := body["data"].(map[string]interface{})["queues"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, 300.0, queue["avg_first_response_seconds"])
+	assert.Nil(t, queue["avg_resolution_seconds"])
+
+	code, _ = get("/api/v1/stats/queues?queue_id=1", 2, false)
+	assert.Equal(t, http.StatusForbidden, code)
+	code, body = get("/api/v1/stats/queues?queue_id=1", 1, false)
+	require.Equal(t, http.StatusOK, code)
+	assert.Equal(t, []float64{1}, queueIDs(body))
+}
@@ -0,0 +1,88 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/goatkit/goatflow/internal/database"
+)
+
+// HandleListQueueTemplatesAPI handles GET /api/v1/queues/:id/templates.
+// With ticket_id set, ticket, customer and agent placeholders are expanded.
+//
+//	@Summary		List response templates for a queue
+//	@Description	List valid response templates assigned to a queue, optionally filtered by type
+//	@Tags			Templates
+//	@Produce		json
+//	@Param			id			path		int		true	"Queue ID"
+//	@Param			type		query		string	false	"Template type (Answer, Forward, Note, ...)"
+//	@Param			ticket_id	query		int		false	"Ticket used to expand placeholders; must be in the queue"
+//	@Success		200			{object}	map[string]interface{}	"Templates"
+//	@Failure		400			{object}	map[string]interface{}	"Invalid request"
+//	@Security		BearerAuth
+//	@Router			/queues/{id}/templates [get]
+func HandleListQueueTemplatesAPI(c *gin.Context) {
+	queueID, err := strconv.Atoi(c.Param("id"))
+	if err != nil || queueID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid queue ID"})
+		return
+	}
+
+	var ticketID int
+	if v := c.Query("ticket_id"); v != "" {
+		ticketID, err = strconv.Atoi(v)
+		if err != nil || ticketID <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid ticket_id"})
+			return
+		}
+	}
+
+	db, err := database.GetDB()
+	if err != nil || db == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"success": false, "error": "Database unavailable"})
+		return
+	}
+
+	if ticketID > 0 {
+		// Only expand against tickets in this queue so queue read access
+		// cannot be used to read another queue's ticket data.
+		var ticketQueueID int
+		err := db.QueryRow(database.ConvertPlaceholders(
+			"SELECT queue_id FROM ticket WHERE id = ?",
+		), ticketID).Scan(&ticketQueueID)
+		if err != nil || ticketQueueID != queueID {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Ticket is not in this queue"})
+			return
+		}
+	}
+
+	templates, err := GetTemplatesForQueue(queueID, c.Query("type"))
+	if err != nil {
+		log.Printf("templates api: listing templates of queue %d failed: %v", queueID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to load templates"})
+		return
+	}
+	if templates == nil {
+		templates = []TemplateForAgent{}
+	}
+
+	if ticketID > 0 {
+		vars := GetTicketTemplateVariables(ticketID)
+		AddCurrentUserTemplateVariables(vars, GetUserIDFromCtx(c, 0))
+		for i := range templates {
+			templates[i].Text = SubstituteTemplateVariables(templates[i].Text, vars)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"queue_id":  queueID,
+			"count":     len(templates),
+			"templates": templates,
+		},
+	})
+}
@@ -0,0 +1,224 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/testutil"
+)
+
+func TestHandleListQueueTemplatesAPI_Validation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name      string
+		url       string
+		wantError string
+	}{
+		{"invalid queue id", "/api/v1/queues/abc/templates", "Invalid queue ID"},
+		{"zero queue id", "/api/v1/queues/0/templates", "Invalid queue ID"},
+		{"invalid ticket id", "/api/v1/queues/1/templates?ticket_id=x", "Invalid ticket_id"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.GET("/api/v1/queues/:id/templates", HandleListQueueTemplatesAPI)
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.url, nil))
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Contains(t, w.Body.String(), tt.wantError)
+		})
+	}
+}
+
+// newQueueTemplateTestDB seeds an agent with rw on every group, a customer,
+// a ticket in queue 1 and one in queue 2, and three templates: "Reply"
+// assigned to queue 1, "Other queue" assigned to queue 2 and "Retired",
+// assigned to queue 1 but invalid. It returns the ticket and template IDs.
+func newQueueTemplateTestDB(t *testing.T) (tickets [2]int64, reply, otherQueue, retired int) {
+	t.Helper()
+	db := testutil.UseMigratedDB(t)
+	for _, stmt := range []string{
+		`INSERT INTO users (id, login, pw, first_name, last_name, valid_id, create_time, create_by, change_time, change_by)
+			VALUES (1, 'root@localhost', 'x', 'Ada', 'Agent', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+		`INSERT INTO group_user (user_id, group_id, permission_key, create_time, create_by, change_time, change_by)
+			SELECT 1, id, 'rw', CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1 FROM groups`,
+		`INSERT INTO customer_user (login, email, customer_id, pw, first_name, last_name, valid_id,
+			create_time, create_by, change_time, change_by)
+			VALUES ('jdoe', 'jdoe@example.com', 'ACME', 'x', 'Jane', 'Doe', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+	} {
+		_, err := db.Exec(stmt)
+		require.NoError(t, err, stmt)
+	}
+
+	for i, queueID := range []int{1, 2} {
+		tn := fmt.Sprintf("202401010000%d", i+1)
+		_, err := db.Exec(database.ConvertPlaceholders(fmt.Sprintf(`
+			INSERT INTO ticket (tn, title, queue_id, %s, ticket_state_id, ticket_priority_id, ticket_lock_id,
+				customer_id, customer_user_id, user_id, responsible_user_id, timeout, until_time,
+				escalation_time, escalation_update_time, escalation_response_time, escalation_solution_time,
+				archive_flag, create_time, create_by, change_time, change_by)
+			VALUES (?, ?, ?, 1, 1, 3, 1, 'ACME', 'jdoe', 1, 1, 0, 0, 0, 0, 0, 0, 0, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+			database.TicketTypeColumn())), tn, "Printer on fire", queueID)
+		require.NoError(t, err)
+		require.NoError(t, db.QueryRow(database.ConvertPlaceholders("SELECT id FROM ticket WHERE tn = ?"), tn).Scan(&tickets[i]))
+	}
+
+	addTemplate := func(name, text string, validID, queueID int) int {
+		_, err := db.Exec(database.ConvertPlaceholders(`
+			INSERT INTO standard_template (name, text, content_type, template_type, valid_id,
+				create_time, create_by, change_time, change_by)
+			VALUES (?, ?, 'text/plain', 'Answer', ?, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`), name, text, validID)
+		require.NoError(t, err)
+		var id int
+		require.NoError(t, db.QueryRow(database.ConvertPlaceholders("SELECT id FROM standard_template WHERE name = ?"), name).Scan(&id))
+		_, err = db.Exec(database.ConvertPlaceholders(`
+			INSERT INTO queue_standard_template (queue_id, standard_template_id, create_time, create_by, change_time, change_by)
+			VALUES (?, ?, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`), queueID, id)
+		require.NoError(t, err)
+		return id
+	}
+	reply = addTemplate("Reply", "Dear <OTRS_CUSTOMER_UserFirstname>,\nabout <OTRS_TICKET_TicketNumber>.\n<OTRS_CURRENT_UserFullname>", 1, 1)
+	otherQueue = addTemplate("Other queue", "Not for queue 1", 1, 2)
+	retired = addTemplate("Retired", "Old text", 2, 1)
+	return tickets, reply, otherQueue, retired
+}
+
+func TestHandleListQueueTemplatesAPI_ExpandsPlaceholders(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tickets, reply, _, _ := newQueueTemplateTestDB(t)
+
+	get := func(url string) (int, map[string]interface{}) {
+		router := gin.New()
+		router.Use(func(c *gin.Context) {
+			c.Set("user_id", 1)
+			c.Next()
+		})
+		router.GET("/api/v1/queues/:id/templates", HandleListQueueTemplatesAPI)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, url, nil))
+		var body map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		return w.Code, body
+	}
+
+	t.Run("with a ticket", func(t *testing.T) {
+		code, body := get(fmt.Sprintf("/api/v1/queues/1/templates?ticket_id=%d", tickets[0]))
+		require.Equal(t, http.StatusOK, code, body)
+		templates := body["data"].(map[string]interface{})["templates"].([]interface{})
+		require.Len(t, templates, 1, "only the valid template of queue 1 is listed")
+		tmpl := templates[0].(map[string]interface{})
+		assert.Equal(t, float64(reply), tmpl["id"])
+		assert.Equal(t, "Dear Jane,\nabout 2024010100001.\nAda Agent", tmpl["text"])
+	})
+
+	t.Run("without a ticket placeholders are kept", func(t *testing.T) {
+		code, body := get("/api/v1/queues/1/templates")
+		require.Equal(t, http.StatusOK, code, body)
+		tmpl := body["data"].(map[string]interface{})["templates"].([]interface{})[0].(map[string]interface{})
+		assert.Contains(t, tmpl["text"], "<OTRS_CUSTOMER_UserFirstname>")
+	})
+
+	t.Run("ticket of another queue", func(t *testing.T) {
+		code, body := get(fmt.Sprintf("/api/v1/queues/1/templates?ticket_id=%d", tickets[1]))
+		assert.Equal(t, http.StatusBadRequest, code)
+		assert.Equal(t, "Ticket is not in this queue", body["error"])
+	})
+}
+
+func TestHandleCreateArticleAPI_FromTemplate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tickets, reply, otherQueue, retired := newQueueTemplateTestDB(t)
+
+	post := func(ticketID int64, payload gin.H, customer bool) (int, map[string]interface{}) {
+		router := gin.New()
+		router.Use(func(c *gin.Context) {
+			c.Set("user_id", 1)
+			c.Set("is_customer", customer)
+			if customer {
+				c.Set("customer_login", "jdoe")
+			}
+			c.Next()
+		})
+		router.POST("/api/v1/tickets/:ticket_id/articles", HandleCreateArticleAPI)
+		raw, err := json.Marshal(payload)
+		require.NoError(t, err)
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, fmt.Sprintf("/api/v1/tickets/%d/articles", ticketID), bytes.NewReader(raw))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		var body map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body), w.Body.String())
+		return w.Code, body
+	}
+
+	t.Run("template body and subject are expanded", func(t *testing.T) {
+		code, body := post(tickets[0], gin.H{
+			"subject":     "Re: <OTRS_TICKET_Title> [<OTRS_TICKET_TicketNumber>]",
+			"template_id": reply,
+		}, false)
+		require.Equal(t, http.StatusCreated, code, body)
+		data := body["data"].(map[string]interface{})
+		assert.Equal(t, "Re: Printer on fire [2024010100001]", data["subject"])
+		assert.Equal(t, "Dear Jane,\nabout 2024010100001.\nAda Agent", data["body"])
+		assert.Equal(t, "text/plain", data["content_type"])
+	})
+
+	t.Run("a given body is kept but expanded", func(t *testing.T) {
+		code, body := post(tickets[0], gin.H{
+			"subject":     "Update",
+			"body":        "Hello <OTRS_CUSTOMER_UserFullname>",
+			"template_id": reply,
+		}, false)
+		require.Equal(t, http.StatusCreated, code, body)
+		assert.Equal(t, "Hello Jane Doe", body["data"].(map[string]interface{})["body"])
+	})
+
+	t.Run("expand_placeholders without a template", func(t *testing.T) {
+		code, body := post(tickets[0], gin.H{
+			"subject":             "Ticket <GOATFLOW_TICKET_TicketNumber>",
+			"body":                "Owner <OTRS_TICKET_Owner>",
+			"expand_placeholders": true,
+		}, false)
+		require.Equal(t, http.StatusCreated, code, body)
+		data := body["data"].(map[string]interface{})
+		assert.Equal(t, "Ticket 2024010100001", data["subject"])
+		assert.Equal(t, "Owner root@localhost", data["body"])
+	})
+
+	errorCases := []struct {
+		name      string
+		ticketID  int64
+		payload   gin.H
+		customer  bool
+		wantError string
+	}{
+		{"unknown template", tickets[0], gin.H{"subject": "x", "template_id": 9999}, false, "Template not found"},
+		{"invalid template", tickets[0], gin.H{"subject": "x", "template_id": retired}, false, "Template not found"},
+		{"template of another queue", tickets[0], gin.H{"subject": "x", "template_id": otherQueue}, false,
+			"Template is not assigned to the ticket's queue"},
+		{"template of the queue on a ticket elsewhere", tickets[1], gin.H{"subject": "x", "template_id": reply}, false,
+			"Template is not assigned to the ticket's queue"},
+		{"customers cannot use templates", tickets[0], gin.H{"subject": "x", "body": "y", "template_id": reply}, true,
+			"Templates are only available to agents"},
+	}
+	for _, tc := range errorCases {
+		t.Run(tc.name, func(t *testing.T) {
+			code, body := post(tc.ticketID, tc.payload, tc.customer)
+			assert.Equal(t, http.StatusBadRequest, code)
+			assert.Equal(t, tc.wantError, body["error"])
+		})
+	}
+}
@@ -0,0 +1,62 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/goatkit/goatflow/internal/database"
+)
+
+// handleReadiness answers the readiness probe. The instance is ready while
+// the primary database answers. Replicas that are down or lagging only
+// degrade it, because their reads fall back to the primary.
+func handleReadiness(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 2*time.Second)
+	defer cancel()
+
+	primary := "ok"
+	db, err := database.GetDB()
+	if err == nil && db != nil {
+		err = db.PingContext(ctx)
+	}
+	if err != nil || db == nil {
+		primary = "unavailable"
+	}
+
+	status := "ready"
+	var replicas []gin.H
+	if set := database.Replicas(); set != nil {
+		for _, r := range set.Status() {
+			entry := gin.H{
+				"name":        r.Name,
+				"healthy":     r.Healthy,
+				"lag_seconds": r.Lag.Seconds(),
+				"checked_at":  r.CheckedAt,
+			}
+			if r.Error != "" {
+				entry["error"] = r.Error
+			}
+			if !r.Healthy {
+				status = "degraded"
+			}
+			replicas = append(replicas, entry)
+		}
+	}
+
+	code := http.StatusOK
+	if primary != "ok" {
+		status = "not_ready"
+		code = http.StatusServiceUnavailable
+	}
+	body := gin.H{
+		"status": status,
+		"checks": gin.H{"database": primary},
+	}
+	if replicas != nil {
+		body["replicas"] = replicas
+	}
+	c.JSON(code, body)
+}
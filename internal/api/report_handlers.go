@@ -0,0 +1,308 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/repository"
+	"github.com/goatkit/goatflow/internal/service"
+)
+
+// reportRequest is the JSON body accepted by the report create/update handlers.
+type reportRequest struct {
+	Name              string                `json:"name" binding:"required"`
+	Description       string                `json:"description"`
+	Metric            models.ReportMetric   `json:"metric" binding:"required"`
+	GroupBy           models.ReportGrouping `json:"group_by"`
+	TimeBucket        models.ReportBucket   `json:"time_bucket"`
+	Filters           models.ReportFilters  `json:"filters"`
+	Schedule          string                `json:"schedule"`
+	ScheduleFormat    models.ReportFormat   `json:"schedule_format"`
+	RecipientGroupIDs []int                 `json:"recipient_group_ids"`
+}
+
+func (r *reportRequest) definition() *models.ReportDefinition {
+	return &models.ReportDefinition{
+		Name:              r.Name,
+		Description:       r.Description,
+		Metric:            r.Metric,
+		GroupBy:           r.GroupBy,
+		TimeBucket:        r.TimeBucket,
+		Filters:           r.Filters,
+		Schedule:          r.Schedule,
+		ScheduleFormat:    r.ScheduleFormat,
+		RecipientGroupIDs: r.RecipientGroupIDs,
+		ValidID:           1,
+	}
+}
+
+func reportService(c *gin.Context) *service.ReportService {
+	db, err := database.GetDB()
+	if err != nil || db == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"success": false, "error": "Database unavailable"})
+		return nil
+	}
+	return service.NewReportService(repository.NewReportRepository(db))
+}
+
+// reportWriteError maps ReportService validation errors to responses.
+func reportWriteError(c *gin.Context, err error, action string) {
+	switch {
+	case errors.Is(err, service.ErrReportNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Report not found"})
+	case errors.Is(err, service.ErrReportNameExists):
+		c.JSON(http.StatusConflict, gin.H{"success": false, "error": err.Error()})
+	case errors.Is(err, service.ErrReportNameRequired),
+		errors.Is(err, service.ErrReportInvalidMetric),
+		errors.Is(err, service.ErrReportInvalidGrouping),
+		errors.Is(err, service.ErrReportInvalidBucket),
+		errors.Is(err, service.ErrReportInvalidFormat),
+		errors.Is(err, service.ErrReportInvalidPeriod),
+		errors.Is(err, service.ErrReportInvalidSchedule),
+		errors.Is(err, service.ErrReportRecipientsRequired):
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+	default:
+		log.Printf("report api: %s failed: %v", action, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to " + action})
+	}
+}
+
+// reportID parses the :id path parameter, writing 400 when it is invalid.
+func reportID(c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid report ID"})
+		return 0, false
+	}
+	return id, true
+}
+
+// HandleListReportsAPI handles GET /api/v1/reports.
+//
+//	@Summary		List report definitions
+//	@Tags			Reports
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}	"Report definitions"
+//	@Security		BearerAuth
+//	@Router			/reports [get]
+func HandleListReportsAPI(c *gin.Context) {
+	svc := reportService(c)
+	if svc == nil {
+		return
+	}
+	defs, err := svc.List(c.Request.Context())
+	if err != nil {
+		log.Printf("report api: listing reports failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to load reports"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": defs})
+}
+
+// HandleCreateReportAPI handles POST /api/v1/reports.
+//
+//	@Summary		Create report definition
+//	@Tags			Reports
+//	@Accept			json
+//	@Produce		json
+//	@Param			report	body		object	true	"Report definition (name, metric, group_by, time_bucket, filters, schedule, schedule_format, recipient_group_ids)"
+//	@Success		201		{object}	map[string]interface{}	"Report created"
+//	@Failure		400		{object}	map[string]interface{}	"Invalid request"
+//	@Failure		409		{object}	map[string]interface{}	"Name already in use"
+//	@Security		BearerAuth
+//	@Router			/reports [post]
+func HandleCreateReportAPI(c *gin.Context) {
+	var req reportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid report request: " + err.Error()})
+		return
+	}
+	// Reject bad definitions before touching the database
+	def := req.definition()
+	if err := service.ValidateReportDefinition(def); err != nil {
+		reportWriteError(c, err, "create report")
+		return
+	}
+	svc := reportService(c)
+	if svc == nil {
+		return
+	}
+
+	def.CreateBy = GetUserIDFromCtx(c, 1)
+	if err := svc.Create(c.Request.Context(), def); err != nil {
+		reportWriteError(c, err, "create report")
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"success": true, "data": def})
+}
+
+// HandleGetReportAPI handles GET /api/v1/reports/:id.
+//
+//	@Summary		Get report definition
+//	@Tags			Reports
+//	@Produce		json
+//	@Param			id	path		int	true	"Report ID"
+//	@Success		200	{object}	map[string]interface{}	"Report definition"
+//	@Failure		404	{object}	map[string]interface{}	"Report not found"
+//	@Security		BearerAuth
+//	@Router			/reports/{id} [get]
+func HandleGetReportAPI(c *gin.Context) {
+	id, ok := reportID(c)
+	if !ok {
+		return
+	}
+	svc := reportService(c)
+	if svc == nil {
+		return
+	}
+	def, err := svc.Get(c.Request.Context(), id)
+	if err != nil {
+		reportWriteError(c, err, "load report")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": def})
+}
+
+// HandleUpdateReportAPI handles PUT /api/v1/reports/:id.
+//
+//	@Summary		Update report definition
+//	@Tags			Reports
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int		true	"Report ID"
+//	@Param			report	body		object	true	"Report definition"
+//	@Success		200		{object}	map[string]interface{}	"Report updated"
+//	@Failure		400		{object}	map[string]interface{}	"Invalid request"
+//	@Failure		404		{object}	map[string]interface{}	"Report not found"
+//	@Security		BearerAuth
+//	@Router			/reports/{id} [put]
+func HandleUpdateReportAPI(c *gin.Context) {
+	id, ok := reportID(c)
+	if !ok {
+		return
+	}
+	var req reportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid report request: " + err.Error()})
+		return
+	}
+	def := req.definition()
+	def.ID = id
+	if err := service.ValidateReportDefinition(def); err != nil {
+		reportWriteError(c, err, "update report")
+		return
+	}
+	svc := reportService(c)
+	if svc == nil {
+		return
+	}
+
+	if err := svc.Update(c.Request.Context(), def, GetUserIDFromCtx(c, 1)); err != nil {
+		reportWriteError(c, err, "update report")
+		return
+	}
+	updated, err := svc.Get(c.Request.Context(), id)
+	if err != nil {
+		reportWriteError(c, err, "load report")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": updated})
+}
+
+// HandleDeleteReportAPI handles DELETE /api/v1/reports/:id.
+//
+//	@Summary		Delete report definition
+//	@Tags			Reports
+//	@Produce		json
+//	@Param			id	path		int	true	"Report ID"
+//	@Success		200	{object}	map[string]interface{}	"Report deleted"
+//	@Failure		404	{object}	map[string]interface{}	"Report not found"
+//	@Security		BearerAuth
+//	@Router			/reports/{id} [delete]
+func HandleDeleteReportAPI(c *gin.Context) {
+	id, ok := reportID(c)
+	if !ok {
+		return
+	}
+	svc := reportService(c)
+	if svc == nil {
+		return
+	}
+	if err := svc.Delete(c.Request.Context(), id, GetUserIDFromCtx(c, 1)); err != nil {
+		reportWriteError(c, err, "delete report")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// HandleRunReportAPI handles GET /api/v1/reports/:id/run.
+//
+//	@Summary		Run report
+//	@Description	Computes the report over its period ending now. format=table returns JSON rows, csv a CSV download and png a bar chart.
+//	@Tags			Reports
+//	@Produce		json
+//	@Produce		text/csv
+//	@Produce		image/png
+//	@Param			id		path		int		true	"Report ID"
+//	@Param			format	query		string	false	"table (default), csv or png"
+//	@Success		200		{object}	map[string]interface{}	"Report result"
+//	@Failure		400		{object}	map[string]interface{}	"Invalid format"
+//	@Failure		404		{object}	map[string]interface{}	"Report not found"
+//	@Security		BearerAuth
+//	@Router			/reports/{id}/run [get]
+func HandleRunReportAPI(c *gin.Context) {
+	id, ok := reportID(c)
+	if !ok {
+		return
+	}
+	format := models.ReportFormat(c.DefaultQuery("format", string(models.ReportFormatTable)))
+	if !format.IsValid() {
+		reportWriteError(c, service.ErrReportInvalidFormat, "run report")
+		return
+	}
+	svc := reportService(c)
+	if svc == nil {
+		return
+	}
+
+	ctx := c.Request.Context()
+	def, err := svc.Get(ctx, id)
+	if err != nil {
+		reportWriteError(c, err, "load report")
+		return
+	}
+	result, err := svc.Run(ctx, def, time.Now())
+	if err != nil {
+		reportWriteError(c, err, "run report")
+		return
+	}
+
+	filename := "report-" + strconv.Itoa(id) + "-" + result.To.Format("20060102")
+	switch format {
+	case models.ReportFormatCSV:
+		data, err := service.RenderReportCSV(result)
+		if err != nil {
+			reportWriteError(c, err, "render report")
+			return
+		}
+		c.Header("Content-Disposition", `attachment; filename="`+filename+`.csv"`)
+		c.Data(http.StatusOK, "text/csv; charset=utf-8", data)
+	case models.ReportFormatPNG:
+		data, err := service.RenderReportPNG(result)
+		if err != nil {
+			reportWriteError(c, err, "render report")
+			return
+		}
+		c.Header("Content-Disposition", `inline; filename="`+filename+`.png"`)
+		c.Data(http.StatusOK, "image/png", data)
+	default:
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": result})
+	}
+}
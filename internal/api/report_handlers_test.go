@@ -0,0 +1,83 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandleCreateReportAPI_Validation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	for name, tc := range map[string]struct {
+		body string
+		want string
+	}{
+		"missing name":     {`{"metric": "tickets_created"}`, "Invalid report request"},
+		"missing metric":   {`{"name": "Weekly"}`, "Invalid report request"},
+		"malformed json":   {`{"name": `, "Invalid report request"},
+		"unknown metric":   {`{"name": "Weekly", "metric": "tickets_open"}`, "metric must be one of"},
+		"unknown grouping": {`{"name": "Weekly", "metric": "tickets_created", "group_by": "agent"}`, "group_by must be one of"},
+		"unknown bucket":   {`{"name": "Weekly", "metric": "tickets_created", "time_bucket": "hour"}`, "time_bucket must be one of"},
+		"period too long":  {`{"name": "Weekly", "metric": "tickets_created", "filters": {"period_days": 1000}}`, "period_days must be between"},
+		"bad schedule":     {`{"name": "Weekly", "metric": "tickets_created", "schedule": "mondays", "recipient_group_ids": [1]}`, "five-field cron"},
+		"no recipients":    {`{"name": "Weekly", "metric": "tickets_created", "schedule": "0 8 * * 1"}`, "recipient group"},
+		"unknown format":   {`{"name": "Weekly", "metric": "tickets_created", "schedule": "@weekly", "schedule_format": "pdf", "recipient_group_ids": [1]}`, "format must be one of"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			router := gin.New()
+			router.POST("/api/v1/reports", HandleCreateReportAPI)
+
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/reports", strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Contains(t, w.Body.String(), tc.want)
+		})
+	}
+}
+
+func TestReportHandlers_InvalidID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.GET("/api/v1/reports/:id", HandleGetReportAPI)
+	router.PUT("/api/v1/reports/:id", HandleUpdateReportAPI)
+	router.DELETE("/api/v1/reports/:id", HandleDeleteReportAPI)
+	router.GET("/api/v1/reports/:id/run", HandleRunReportAPI)
+
+	for _, r := range []struct{ method, path string }{
+		{http.MethodGet, "/api/v1/reports/abc"},
+		{http.MethodPut, "/api/v1/reports/0"},
+		{http.MethodDelete, "/api/v1/reports/-1"},
+		{http.MethodGet, "/api/v1/reports/x/run"},
+	} {
+		req := httptest.NewRequest(r.method, r.path, strings.NewReader(`{}`))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, r.method+" "+r.path)
+		assert.Contains(t, w.Body.String(), "Invalid report ID")
+	}
+}
+
+func TestHandleRunReportAPI_InvalidFormat(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.GET("/api/v1/reports/:id/run", HandleRunReportAPI)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/reports/1/run?format=pdf", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "format must be one of")
+}
@@ -0,0 +1,352 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/goatkit/goatflow/internal/middleware"
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/service"
+)
+
+// requestCaptureRequest is the JSON body accepted by the capture create
+// handler.
+type requestCaptureRequest struct {
+	Name        string `json:"name" binding:"required"`
+	Method      string `json:"method"`
+	PathPrefix  string `json:"path_prefix"`
+	Principal   string `json:"principal"`
+	MaxRequests int    `json:"max_requests"`
+	// DurationMinutes is how long the capture records; 0 means one hour.
+	DurationMinutes int `json:"duration_minutes"`
+}
+
+// replayRequest is the JSON body accepted by the replay handler.
+type replayRequest struct {
+	Target string   `json:"target" binding:"required"`
+	Token  string   `json:"token"`
+	Body   *string  `json:"body"`
+	Ignore []string `json:"ignore"`
+}
+
+// requestCaptureService creates a request capture service from the server
+// configuration, writing an error response when it is unavailable.
+func requestCaptureService(c *gin.Context) *service.RequestCaptureService {
+	svc, err := middleware.NewRequestCaptureService()
+	switch {
+	case err != nil:
+		c.JSON(http.StatusServiceUnavailable, gin.H{"success": false, "error": "Database unavailable"})
+		return nil
+	case svc == nil:
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": "Request capture is disabled (server.request_capture.enabled)"})
+		return nil
+	}
+	return svc
+}
+
+// requestCaptureWriteError maps RequestCaptureService errors to responses.
+func requestCaptureWriteError(c *gin.Context, err error, action string) {
+	switch {
+	case errors.Is(err, service.ErrRequestCaptureNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Request capture not found"})
+	case errors.Is(err, service.ErrCapturedRequestNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Captured request not found"})
+	case errors.Is(err, service.ErrRequestCaptureName),
+		errors.Is(err, service.ErrRequestCaptureMethod),
+		errors.Is(err, service.ErrRequestCapturePath),
+		errors.Is(err, service.ErrRequestCapturePrincipal),
+		errors.Is(err, service.ErrRequestCaptureLimit),
+		errors.Is(err, service.ErrRequestCaptureExpiry),
+		errors.Is(err, service.ErrReplayTargetUnknown):
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+	default:
+		log.Printf("request capture api: %s failed: %v", action, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to " + action})
+	}
+}
+
+// requestCaptureID parses the :id path parameter, writing 400 when it is
+// invalid.
+func requestCaptureID(c *gin.Context, what string) (int64, bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid " + what + " ID"})
+		return 0, false
+	}
+	return id, true
+}
+
+// HandleListRequestCapturesAPI handles GET /api/v1/request-captures.
+//
+//	@Summary		List request captures
+//	@Description	Returns every capture with the number of requests it recorded, and the configured replay target names.
+//	@Tags			Request Capture
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}	"Request captures"
+//	@Security		BearerAuth
+//	@Router			/request-captures [get]
+func HandleListRequestCapturesAPI(c *gin.Context) {
+	svc := requestCaptureService(c)
+	if svc == nil {
+		return
+	}
+	captures, err := svc.ListCaptures(c.Request.Context())
+	if err != nil {
+		requestCaptureWriteError(c, err, "load request captures")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": captures, "replay_targets": svc.ReplayTargets()})
+}
+
+// HandleCreateRequestCaptureAPI handles POST /api/v1/request-captures.
+//
+//	@Summary		Start a request capture
+//	@Description	Records API requests matching the method, path prefix and caller until the capture expires or is full. Secrets are redacted before storing.
+//	@Tags			Request Capture
+//	@Accept			json
+//	@Produce		json
+//	@Param			capture	body		object	true	"Capture (name, method, path_prefix, principal, max_requests, duration_minutes)"
+//	@Success		201		{object}	map[string]interface{}	"Capture started"
+//	@Failure		400		{object}	map[string]interface{}	"Invalid request"
+//	@Security		BearerAuth
+//	@Router			/request-captures [post]
+func HandleCreateRequestCaptureAPI(c *gin.Context) {
+	var req requestCaptureRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid request capture: " + err.Error()})
+		return
+	}
+	if req.DurationMinutes < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "duration_minutes must not be negative"})
+		return
+	}
+	svc := requestCaptureService(c)
+	if svc == nil {
+		return
+	}
+	rc := &models.RequestCapture{
+		Name:        req.Name,
+		Method:      req.Method,
+		PathPrefix:  req.PathPrefix,
+		Principal:   req.Principal,
+		MaxRequests: req.MaxRequests,
+	}
+	if req.DurationMinutes > 0 {
+		rc.ExpiresTime = time.Now().Add(time.Duration(req.DurationMinutes) * time.Minute)
+	}
+	created, err := svc.CreateCapture(c.Request.Context(), rc, GetUserIDFromCtx(c, 1))
+	if err != nil {
+		requestCaptureWriteError(c, err, "create request capture")
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"success": true, "data": created})
+}
+
+// HandleGetRequestCaptureAPI handles GET /api/v1/request-captures/:id.
+//
+//	@Summary		Get request capture
+//	@Tags			Request Capture
+//	@Produce		json
+//	@Param			id	path		int	true	"Capture ID"
+//	@Success		200	{object}	map[string]interface{}	"Request capture"
+//	@Failure		404	{object}	map[string]interface{}	"Request capture not found"
+//	@Security		BearerAuth
+//	@Router			/request-captures/{id} [get]
+func HandleGetRequestCaptureAPI(c *gin.Context) {
+	id, ok := requestCaptureID(c, "request capture")
+	if !ok {
+		return
+	}
+	svc := requestCaptureService(c)
+	if svc == nil {
+		return
+	}
+	rc, err := svc.GetCapture(c.Request.Context(), id)
+	if err != nil {
+		requestCaptureWriteError(c, err, "load request capture")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": rc})
+}
+
+// HandleStopRequestCaptureAPI handles POST /api/v1/request-captures/:id/stop.
+//
+//	@Summary		Stop request capture
+//	@Description	Ends the capture before it expires. Recorded requests are kept.
+//	@Tags			Request Capture
+//	@Produce		json
+//	@Param			id	path		int	true	"Capture ID"
+//	@Success		200	{object}	map[string]interface{}	"Request capture stopped"
+//	@Failure		404	{object}	map[string]interface{}	"Request capture not found"
+//	@Security		BearerAuth
+//	@Router			/request-captures/{id}/stop [post]
+func HandleStopRequestCaptureAPI(c *gin.Context) {
+	id, ok := requestCaptureID(c, "request capture")
+	if !ok {
+		return
+	}
+	svc := requestCaptureService(c)
+	if svc == nil {
+		return
+	}
+	rc, err := svc.StopCapture(c.Request.Context(), id, GetUserIDFromCtx(c, 1))
+	if err != nil {
+		requestCaptureWriteError(c, err, "stop request capture")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": rc})
+}
+
+// HandleDeleteRequestCaptureAPI handles DELETE /api/v1/request-captures/:id.
+//
+//	@Summary		Delete request capture
+//	@Description	Deletes the capture with its recorded requests and replays.
+//	@Tags			Request Capture
+//	@Produce		json
+//	@Param			id	path		int	true	"Capture ID"
+//	@Success		200	{object}	map[string]interface{}	"Request capture deleted"
+//	@Failure		404	{object}	map[string]interface{}	"Request capture not found"
+//	@Security		BearerAuth
+//	@Router			/request-captures/{id} [delete]
+func HandleDeleteRequestCaptureAPI(c *gin.Context) {
+	id, ok := requestCaptureID(c, "request capture")
+	if !ok {
+		return
+	}
+	svc := requestCaptureService(c)
+	if svc == nil {
+		return
+	}
+	if err := svc.DeleteCapture(c.Request.Context(), id); err != nil {
+		requestCaptureWriteError(c, err, "delete request capture")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// HandleListCapturedRequestsAPI handles GET /api/v1/request-captures/:id/requests.
+//
+//	@Summary		List captured requests
+//	@Tags			Request Capture
+//	@Produce		json
+//	@Param			id	path		int	true	"Capture ID"
+//	@Success		200	{object}	map[string]interface{}	"Captured requests, oldest first"
+//	@Failure		404	{object}	map[string]interface{}	"Request capture not found"
+//	@Security		BearerAuth
+//	@Router			/request-captures/{id}/requests [get]
+func HandleListCapturedRequestsAPI(c *gin.Context) {
+	id, ok := requestCaptureID(c, "request capture")
+	if !ok {
+		return
+	}
+	svc := requestCaptureService(c)
+	if svc == nil {
+		return
+	}
+	requests, err := svc.ListRequests(c.Request.Context(), id)
+	if err != nil {
+		requestCaptureWriteError(c, err, "load captured requests")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": requests})
+}
+
+// HandleGetCapturedRequestAPI handles GET /api/v1/captured-requests/:id.
+//
+//	@Summary		Get captured request
+//	@Tags			Request Capture
+//	@Produce		json
+//	@Param			id	path		int	true	"Captured request ID"
+//	@Success		200	{object}	map[string]interface{}	"Captured request"
+//	@Failure		404	{object}	map[string]interface{}	"Captured request not found"
+//	@Security		BearerAuth
+//	@Router			/captured-requests/{id} [get]
+func HandleGetCapturedRequestAPI(c *gin.Context) {
+	id, ok := requestCaptureID(c, "captured request")
+	if !ok {
+		return
+	}
+	svc := requestCaptureService(c)
+	if svc == nil {
+		return
+	}
+	r, err := svc.GetRequest(c.Request.Context(), id)
+	if err != nil {
+		requestCaptureWriteError(c, err, "load captured request")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": r})
+}
+
+// HandleReplayCapturedRequestAPI handles POST /api/v1/captured-requests/:id/replay.
+//
+//	@Summary		Replay captured request
+//	@Description	Sends the captured request to a configured replay target and compares the response with the captured one. The token is sent as bearer token and not stored; body replaces the captured body, e.g. to fill in redacted values.
+//	@Tags			Request Capture
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int		true	"Captured request ID"
+//	@Param			replay	body		object	true	"Replay (target, token, body, ignore)"
+//	@Success		201		{object}	map[string]interface{}	"Replay with its differences"
+//	@Failure		400		{object}	map[string]interface{}	"Invalid request or unknown target"
+//	@Failure		404		{object}	map[string]interface{}	"Captured request not found"
+//	@Security		BearerAuth
+//	@Router			/captured-requests/{id}/replay [post]
+func HandleReplayCapturedRequestAPI(c *gin.Context) {
+	id, ok := requestCaptureID(c, "captured request")
+	if !ok {
+		return
+	}
+	var req replayRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid replay: " + err.Error()})
+		return
+	}
+	svc := requestCaptureService(c)
+	if svc == nil {
+		return
+	}
+	replay, err := svc.Replay(c.Request.Context(), id, service.ReplayRequest{
+		Target: req.Target,
+		Token:  req.Token,
+		Body:   req.Body,
+		Ignore: req.Ignore,
+	}, GetUserIDFromCtx(c, 1))
+	if err != nil {
+		requestCaptureWriteError(c, err, "replay captured request")
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"success": true, "data": replay})
+}
+
+// HandleListRequestReplaysAPI handles GET /api/v1/captured-requests/:id/replays.
+//
+//	@Summary		List replays of a captured request
+//	@Tags			Request Capture
+//	@Produce		json
+//	@Param			id	path		int	true	"Captured request ID"
+//	@Success		200	{object}	map[string]interface{}	"Replays, newest first"
+//	@Failure		404	{object}	map[string]interface{}	"Captured request not found"
+//	@Security		BearerAuth
+//	@Router			/captured-requests/{id}/replays [get]
+func HandleListRequestReplaysAPI(c *gin.Context) {
+	id, ok := requestCaptureID(c, "captured request")
+	if !ok {
+		return
+	}
+	svc := requestCaptureService(c)
+	if svc == nil {
+		return
+	}
+	replays, err := svc.ListReplays(c.Request.Context(), id)
+	if err != nil {
+		requestCaptureWriteError(c, err, "load replays")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": replays})
+}
@@ -0,0 +1,36 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestCaptureHandlers_InvalidInput(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.GET("/api/v1/request-captures/:id", HandleGetRequestCaptureAPI)
+	router.POST("/api/v1/request-captures", HandleCreateRequestCaptureAPI)
+	router.POST("/api/v1/captured-requests/:id/replay", HandleReplayCapturedRequestAPI)
+
+	for _, r := range []struct{ method, path, body, want string }{
+		{http.MethodGet, "/api/v1/request-captures/abc", "", "Invalid request capture ID"},
+		{http.MethodPost, "/api/v1/request-captures", `{"path_prefix":"/api/v1/tickets"}`, "Invalid request capture"},
+		{http.MethodPost, "/api/v1/request-captures", `{"name":"x","duration_minutes":-5}`, "duration_minutes"},
+		{http.MethodPost, "/api/v1/captured-requests/0/replay", `{"target":"staging"}`, "Invalid captured request ID"},
+		{http.MethodPost, "/api/v1/captured-requests/1/replay", `{"token":"gf_x"}`, "Invalid replay"},
+	} {
+		req := httptest.NewRequest(r.method, r.path, strings.NewReader(r.body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, r.path)
+		assert.Contains(t, w.Body.String(), r.want, r.path)
+	}
+}
@@ -0,0 +1,383 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/service"
+)
+
+// roleUsersRequest is the JSON body that replaces a role's members.
+type roleUsersRequest struct {
+	UserIDs []int `json:"user_ids"`
+}
+
+// rolePermissionsRequest is the JSON body that replaces a role's group
+// permissions.
+type rolePermissionsRequest struct {
+	Permissions []*models.RoleGroupPermissions `json:"permissions"`
+}
+
+// userRolesRequest is the JSON body that replaces an agent's roles.
+type userRolesRequest struct {
+	RoleIDs []int `json:"role_ids"`
+}
+
+// roleService creates a role service, writing 503 when the database is
+// unavailable.
+func roleService(c *gin.Context) *service.RoleService {
+	db, err := database.GetDB()
+	if err != nil || db == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"success": false, "error": "Database unavailable"})
+		return nil
+	}
+	return service.NewRoleService(db)
+}
+
+// rolePathID parses the :id path parameter, writing 400 when it is not a
+// positive number.
+func rolePathID(c *gin.Context, what string) (int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid " + what + " ID"})
+		return 0, false
+	}
+	return id, true
+}
+
+// roleWriteError maps RoleService errors to responses.
+func roleWriteError(c *gin.Context, err error, action string) {
+	switch {
+	case errors.Is(err, service.ErrRoleNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Role not found"})
+	case errors.Is(err, service.ErrRoleAgentNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "User not found"})
+	case errors.Is(err, service.ErrRoleNameTaken):
+		c.JSON(http.StatusConflict, gin.H{"success": false, "error": err.Error()})
+	case errors.Is(err, service.ErrRoleNameRequired),
+		errors.Is(err, service.ErrRoleInvalidPermission),
+		errors.Is(err, service.ErrRoleGroupNotFound),
+		errors.Is(err, service.ErrRoleUserNotFound),
+		errors.Is(err, service.ErrRoleRoleNotFound):
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+	default:
+		log.Printf("role api: %s failed: %v", action, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to " + action})
+	}
+}
+
+// HandleListRolesAPI handles GET /api/v1/roles.
+//
+//	@Summary		List roles
+//	@Description	Returns the roles ordered by name. Invalid roles are included with include_invalid=true.
+//	@Tags			Roles
+//	@Produce		json
+//	@Param			include_invalid	query		bool					false	"Include invalid roles"
+//	@Success		200				{object}	map[string]interface{}	"Roles"
+//	@Security		BearerAuth
+//	@Router			/roles [get]
+func HandleListRolesAPI(c *gin.Context) {
+	svc := roleService(c)
+	if svc == nil {
+		return
+	}
+	roles, err := svc.List(c.Query("include_invalid") == "true")
+	if err != nil {
+		roleWriteError(c, err, "list roles")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": roles})
+}
+
+// HandleCreateRoleAPI handles POST /api/v1/roles.
+//
+//	@Summary		Create a role
+//	@Description	Creates a role with its group permissions. Agents assigned the role get these permissions in addition to their own.
+//	@Tags			Roles
+//	@Accept			json
+//	@Produce		json
+//	@Param			role	body		object					true	"Role (name, comments, valid_id, permissions)"
+//	@Success		201		{object}	map[string]interface{}	"Created role"
+//	@Failure		400		{object}	map[string]interface{}	"Invalid request"
+//	@Failure		409		{object}	map[string]interface{}	"Name taken"
+//	@Security		BearerAuth
+//	@Router			/roles [post]
+func HandleCreateRoleAPI(c *gin.Context) {
+	var input service.RoleInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid request: " + err.Error()})
+		return
+	}
+	svc := roleService(c)
+	if svc == nil {
+		return
+	}
+	role, err := svc.Create(input, GetUserIDFromCtx(c, 1))
+	if err != nil {
+		roleWriteError(c, err, "create role")
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"success": true, "data": role})
+}
+
+// HandleGetRoleAPI handles GET /api/v1/roles/:id.
+//
+//	@Summary		Get a role
+//	@Description	Returns a role with its members and group permissions.
+//	@Tags			Roles
+//	@Produce		json
+//	@Param			id	path		int						true	"Role ID"
+//	@Success		200	{object}	map[string]interface{}	"Role"
+//	@Failure		404	{object}	map[string]interface{}	"Role not found"
+//	@Security		BearerAuth
+//	@Router			/roles/{id} [get]
+func HandleGetRoleAPI(c *gin.Context) {
+	id, ok := rolePathID(c, "role")
+	if !ok {
+		return
+	}
+	svc := roleService(c)
+	if svc == nil {
+		return
+	}
+	role, err := svc.Get(id)
+	if err != nil {
+		roleWriteError(c, err, "load role")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": role})
+}
+
+// HandleUpdateRoleAPI handles PUT /api/v1/roles/:id.
+//
+//	@Summary		Update a role
+//	@Description	Changes a role's name, comments and validity, and its group permissions when permissions is present.
+//	@Tags			Roles
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int						true	"Role ID"
+//	@Param			role	body		object					true	"Role (name, comments, valid_id, permissions)"
+//	@Success		200		{object}	map[string]interface{}	"Updated role"
+//	@Failure		400		{object}	map[string]interface{}	"Invalid request"
+//	@Failure		404		{object}	map[string]interface{}	"Role not found"
+//	@Failure		409		{object}	map[string]interface{}	"Name taken"
+//	@Security		BearerAuth
+//	@Router			/roles/{id} [put]
+func HandleUpdateRoleAPI(c *gin.Context) {
+	id, ok := rolePathID(c, "role")
+	if !ok {
+		return
+	}
+	var input service.RoleInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid request: " + err.Error()})
+		return
+	}
+	svc := roleService(c)
+	if svc == nil {
+		return
+	}
+	role, err := svc.Update(id, input, GetUserIDFromCtx(c, 1))
+	if err != nil {
+		roleWriteError(c, err, "update role")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": role})
+}
+
+// HandleDeleteRoleAPI handles DELETE /api/v1/roles/:id.
+//
+//	@Summary		Delete a role
+//	@Description	Marks the role invalid. Its members keep the assignment but no longer get its permissions.
+//	@Tags			Roles
+//	@Produce		json
+//	@Param			id	path		int						true	"Role ID"
+//	@Success		200	{object}	map[string]interface{}	"Role invalidated"
+//	@Failure		404	{object}	map[string]interface{}	"Role not found"
+//	@Security		BearerAuth
+//	@Router			/roles/{id} [delete]
+func HandleDeleteRoleAPI(c *gin.Context) {
+	id, ok := rolePathID(c, "role")
+	if !ok {
+		return
+	}
+	svc := roleService(c)
+	if svc == nil {
+		return
+	}
+	if err := svc.Delete(id, GetUserIDFromCtx(c, 1)); err != nil {
+		roleWriteError(c, err, "delete role")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Role deleted"})
+}
+
+// HandleSetRoleUsersAPI handles PUT /api/v1/roles/:id/users.
+//
+//	@Summary		Set a role's members
+//	@Description	Replaces the agents assigned to the role.
+//	@Tags			Roles
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int						true	"Role ID"
+//	@Param			users	body		object					true	"Members (user_ids)"
+//	@Success		200		{object}	map[string]interface{}	"Updated role"
+//	@Failure		400		{object}	map[string]interface{}	"Invalid request"
+//	@Failure		404		{object}	map[string]interface{}	"Role not found"
+//	@Security		BearerAuth
+//	@Router			/roles/{id}/users [put]
+func HandleSetRoleUsersAPI(c *gin.Context) {
+	id, ok := rolePathID(c, "role")
+	if !ok {
+		return
+	}
+	var req roleUsersRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid request: " + err.Error()})
+		return
+	}
+	svc := roleService(c)
+	if svc == nil {
+		return
+	}
+	role, err := svc.SetUsers(id, req.UserIDs, GetUserIDFromCtx(c, 1))
+	if err != nil {
+		roleWriteError(c, err, "set role members")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": role})
+}
+
+// HandleSetRolePermissionsAPI handles PUT /api/v1/roles/:id/permissions.
+//
+//	@Summary		Set a role's group permissions
+//	@Description	Replaces the group permissions the role grants. Keys are ro, move_into, create, note, owner, priority and rw.
+//	@Tags			Roles
+//	@Accept			json
+//	@Produce		json
+//	@Param			id			path		int						true	"Role ID"
+//	@Param			permissions	body		object					true	"Permissions (list of group_id and permissions)"
+//	@Success		200			{object}	map[string]interface{}	"Updated role"
+//	@Failure		400			{object}	map[string]interface{}	"Invalid request"
+//	@Failure		404			{object}	map[string]interface{}	"Role not found"
+//	@Security		BearerAuth
+//	@Router			/roles/{id}/permissions [put]
+func HandleSetRolePermissionsAPI(c *gin.Context) {
+	id, ok := rolePathID(c, "role")
+	if !ok {
+		return
+	}
+	var req rolePermissionsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid request: " + err.Error()})
+		return
+	}
+	svc := roleService(c)
+	if svc == nil {
+		return
+	}
+	role, err := svc.SetPermissions(id, req.Permissions, GetUserIDFromCtx(c, 1))
+	if err != nil {
+		roleWriteError(c, err, "set role permissions")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": role})
+}
+
+// HandleGetUserRolesAPI handles GET /api/v1/users/:id/roles.
+//
+//	@Summary		Get an agent's roles
+//	@Description	Returns the roles assigned to the agent, invalid ones included.
+//	@Tags			Roles
+//	@Produce		json
+//	@Param			id	path		int						true	"User ID"
+//	@Success		200	{object}	map[string]interface{}	"Roles"
+//	@Security		BearerAuth
+//	@Router			/users/{id}/roles [get]
+func HandleGetUserRolesAPI(c *gin.Context) {
+	id, ok := rolePathID(c, "user")
+	if !ok {
+		return
+	}
+	svc := roleService(c)
+	if svc == nil {
+		return
+	}
+	roles, err := svc.UserRoles(id)
+	if err != nil {
+		roleWriteError(c, err, "load user roles")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": roles})
+}
+
+// HandleSetUserRolesAPI handles PUT /api/v1/users/:id/roles.
+//
+//	@Summary		Set an agent's roles
+//	@Description	Replaces the roles assigned to the agent.
+//	@Tags			Roles
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int						true	"User ID"
+//	@Param			roles	body		object					true	"Roles (role_ids)"
+//	@Success		200		{object}	map[string]interface{}	"Roles"
+//	@Failure		400		{object}	map[string]interface{}	"Invalid request"
+//	@Failure		404		{object}	map[string]interface{}	"User not found"
+//	@Security		BearerAuth
+//	@Router			/users/{id}/roles [put]
+func HandleSetUserRolesAPI(c *gin.Context) {
+	id, ok := rolePathID(c, "user")
+	if !ok {
+		return
+	}
+	var req userRolesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid request: " + err.Error()})
+		return
+	}
+	svc := roleService(c)
+	if svc == nil {
+		return
+	}
+	roles, err := svc.SetUserRoles(id, req.RoleIDs, GetUserIDFromCtx(c, 1))
+	if err != nil {
+		roleWriteError(c, err, "set user roles")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": roles})
+}
+
+// HandleGetUserEffectivePermissionsAPI handles
+// GET /api/v1/users/:id/effective-permissions.
+//
+//	@Summary		Get an agent's effective group permissions
+//	@Description	Returns the agent's group permissions from direct assignments and valid roles, with the source of each.
+//	@Tags			Roles
+//	@Produce		json
+//	@Param			id	path		int						true	"User ID"
+//	@Success		200	{object}	map[string]interface{}	"Effective permissions"
+//	@Failure		404	{object}	map[string]interface{}	"User not found"
+//	@Security		BearerAuth
+//	@Router			/users/{id}/effective-permissions [get]
+func HandleGetUserEffectivePermissionsAPI(c *gin.Context) {
+	id, ok := rolePathID(c, "user")
+	if !ok {
+		return
+	}
+	svc := roleService(c)
+	if svc == nil {
+		return
+	}
+	perms, err := svc.EffectivePermissions(id)
+	if err != nil {
+		roleWriteError(c, err, "load effective permissions")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": perms})
+}
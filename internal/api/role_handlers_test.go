@@ -0,0 +1,38 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRoleAPI_InvalidRequests(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.GET("/api/v1/roles/:id", HandleGetRoleAPI)
+	router.PUT("/api/v1/roles/:id/users", HandleSetRoleUsersAPI)
+	router.PUT("/api/v1/users/:id/roles", HandleSetUserRolesAPI)
+
+	for _, tc := range []struct {
+		method, path, body, want string
+	}{
+		{http.MethodGet, "/api/v1/roles/abc", "", "Invalid role ID"},
+		{http.MethodGet, "/api/v1/roles/0", "", "Invalid role ID"},
+		{http.MethodPut, "/api/v1/roles/1/users", `{"user_ids":"2"}`, "Invalid request"},
+		{http.MethodPut, "/api/v1/users/x/roles", `{"role_ids":[1]}`, "Invalid user ID"},
+		{http.MethodPut, "/api/v1/users/2/roles", `{"role_ids":`, "Invalid request"},
+	} {
+		req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, tc.path)
+		assert.Contains(t, w.Body.String(), tc.want, tc.path)
+	}
+}
@@ -4,15 +4,52 @@ import (
 	"context"
 	"net/http"
 	"os"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
 
+	"github.com/goatkit/goatflow/internal/config"
+	"github.com/goatkit/goatflow/internal/middleware"
 	"github.com/goatkit/goatflow/internal/search"
+	"github.com/goatkit/goatflow/internal/service"
 )
 
 var searchManager *search.SearchManager
 
+const (
+	defaultArchiveSearchPerHour     = 60
+	defaultArchiveSearchConcurrency = 2
+	archiveSearchTimeout            = 30 * time.Second
+)
+
+// archiveSearchSlots bounds concurrent archive scans so they cannot starve
+// live-queue searches of backend capacity. Sized lazily once config is loaded.
+var (
+	archiveSearchSlots     chan struct{}
+	archiveSearchSlotsOnce sync.Once
+)
+
+func archiveSearchConcurrency() int {
+	if cfg := config.Get(); cfg != nil && cfg.RateLimiting.ArchiveSearchConcurrency > 0 {
+		return cfg.RateLimiting.ArchiveSearchConcurrency
+	}
+	return defaultArchiveSearchConcurrency
+}
+
+func archiveSearchPerHour() int {
+	if cfg := config.Get(); cfg != nil && cfg.RateLimiting.ArchiveSearchPerHour > 0 {
+		return cfg.RateLimiting.ArchiveSearchPerHour
+	}
+	return defaultArchiveSearchPerHour
+}
+
+// TicketSearchIndexer returns the indexer that keeps the configured search
+// backends in step with archived and restored tickets.
+func TicketSearchIndexer() service.TicketIndexer {
+	return searchManager
+}
+
 func init() {
 	// Initialize search manager
 	searchManager = search.NewSearchManager()
@@ -57,7 +94,8 @@ func init() {
 // HandleSearchAPI handles POST /api/v1/search.
 //
 //	@Summary		Search tickets
-//	@Description	Full-text search across tickets
+//	@Description	Full-text search across tickets. Set include_archived to also search
+//	@Description	archived tickets; archive searches have their own rate limit.
 //	@Tags			Search
 //	@Accept			json
 //	@Produce		json
@@ -65,6 +103,7 @@ func init() {
 //	@Success		200		{object}	map[string]interface{}	"Search results"
 //	@Failure		400		{object}	map[string]interface{}	"Invalid request"
 //	@Failure		401		{object}	map[string]interface{}	"Unauthorized"
+//	@Failure		429		{object}	map[string]interface{}	"Archive search rate limit exceeded"
 //	@Security		BearerAuth
 //	@Router			/search [post]
 func HandleSearchAPI(c *gin.Context) {
@@ -99,8 +138,29 @@ func HandleSearchAPI(c *gin.Context) {
 		req.Limit = 100 // Max limit
 	}
 
+	// Archive scans are opt-in, separately rate limited and capped in concurrency
+	timeout := 10 * time.Second
+	if req.IncludeArchived {
+		if !middleware.AllowArchiveSearch(c, archiveSearchPerHour()) {
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Archive search rate limit exceeded"})
+			return
+		}
+		archiveSearchSlotsOnce.Do(func() {
+			archiveSearchSlots = make(chan struct{}, archiveSearchConcurrency())
+		})
+		select {
+		case archiveSearchSlots <- struct{}{}:
+			defer func() { <-archiveSearchSlots }()
+		default:
+			c.Header("Retry-After", "5")
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many concurrent archive searches"})
+			return
+		}
+		timeout = archiveSearchTimeout
+	}
+
 	// Create context with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 
 	// If no backend available (common in tests without DB), return empty results
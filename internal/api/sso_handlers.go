@@ -0,0 +1,421 @@
+package api
+
+import (
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/goatkit/goatflow/internal/auth"
+	"github.com/goatkit/goatflow/internal/constants"
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/middleware"
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/service"
+	"github.com/goatkit/goatflow/internal/shared"
+)
+
+// ssoStateCookie binds a pending SSO login to the browser that started it,
+// so a login started elsewhere cannot be completed in the victim's browser.
+const ssoStateCookie = "sso_state"
+
+// ssoOIDCClients caches one OIDC client (and with it the discovery document
+// and signing keys) per provider, replaced when the provider or host changes.
+var ssoOIDCClients sync.Map
+
+type ssoCachedOIDCClient struct {
+	version string
+	client  *auth.OIDCClient
+}
+
+// ssoBaseURL is the scheme and host the browser used to reach GoatFlow.
+func ssoBaseURL(c *gin.Context) string {
+	scheme := "http"
+	if ssoSecureRequest(c) {
+		scheme = "https"
+	}
+	return scheme + "://" + c.Request.Host
+}
+
+func ssoSecureRequest(c *gin.Context) bool {
+	return c.Request.TLS != nil || strings.EqualFold(c.GetHeader("X-Forwarded-Proto"), "https")
+}
+
+// ssoLoginPath is the login page to return to when a provider login fails.
+func ssoLoginPath(frontend models.SSOFrontend) string {
+	if frontend == models.SSOFrontendCustomer {
+		return "/customer/login"
+	}
+	return "/login"
+}
+
+func ssoOIDCClient(c *gin.Context, p *models.SSOProvider) *auth.OIDCClient {
+	version := fmt.Sprintf("%d:%s", p.ChangeTime.UnixNano(), c.Request.Host)
+	if cached, ok := ssoOIDCClients.Load(p.ID); ok && cached.(ssoCachedOIDCClient).version == version {
+		return cached.(ssoCachedOIDCClient).client
+	}
+	client := auth.NewOIDCClient(auth.OIDCConfig{
+		Issuer:       p.Config.Issuer,
+		ClientID:     p.Config.ClientID,
+		ClientSecret: p.Config.ClientSecret,
+		RedirectURL:  fmt.Sprintf("%s/auth/sso/%d/callback", ssoBaseURL(c), p.ID),
+		Scopes:       p.Config.Scopes,
+	}, nil)
+	ssoOIDCClients.Store(p.ID, ssoCachedOIDCClient{version: version, client: client})
+	return client
+}
+
+func ssoSAMLProvider(c *gin.Context, p *models.SSOProvider) (*auth.SAMLServiceProvider, error) {
+	base := fmt.Sprintf("%s/auth/sso/%d", ssoBaseURL(c), p.ID)
+	entityID := p.Config.SPEntityID
+	if entityID == "" {
+		entityID = base + "/metadata"
+	}
+	return auth.NewSAMLServiceProvider(auth.SAMLConfig{
+		IdPSSOURL:      p.Config.IdPSSOURL,
+		IdPEntityID:    p.Config.IdPEntityID,
+		IdPCertificate: p.Config.IdPCertificate,
+		SPEntityID:     entityID,
+		ACSURL:         base + "/acs",
+	})
+}
+
+// ssoLoginProviders lists the enabled providers for a login page. Errors
+// only hide the buttons; password login keeps working.
+func ssoLoginProviders(c *gin.Context, frontend models.SSOFrontend) []gin.H {
+	providers := make([]gin.H, 0)
+	db, err := database.GetDB()
+	if err != nil || db == nil {
+		return providers
+	}
+	list, err := service.NewSSOService(db).EnabledProviders(c.Request.Context(), frontend)
+	if err != nil {
+		return providers
+	}
+	portalProvider := 0
+	if d := portalDomainForRequest(c); d != nil && frontend == models.SSOFrontendCustomer {
+		portalProvider = d.SSOProviderID
+	}
+	for _, p := range list {
+		if portalProvider > 0 && p.ID != portalProvider {
+			continue
+		}
+		providers = append(providers, gin.H{"ID": p.ID, "Name": p.Name, "URL": fmt.Sprintf("/auth/sso/%d/login", p.ID)})
+	}
+	return providers
+}
+
+// ssoLoginProvider loads the enabled provider named by :id for the login
+// flow, answering 404 when there is none.
+func ssoLoginProvider(c *gin.Context, protocol models.SSOProtocol) (*sql.DB, *models.SSOProvider, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		c.String(http.StatusNotFound, "Unknown sign-in provider")
+		return nil, nil, false
+	}
+	db, err := database.GetDB()
+	if err != nil || db == nil {
+		c.String(http.StatusServiceUnavailable, "Database unavailable")
+		return nil, nil, false
+	}
+	p, err := service.NewSSOService(db).GetProvider(c.Request.Context(), id)
+	if err != nil || p.ValidID != 1 || (protocol != "" && p.Protocol != protocol) {
+		c.String(http.StatusNotFound, "Unknown sign-in provider")
+		return nil, nil, false
+	}
+	// A portal domain with its own identity provider offers only that one
+	if d := portalDomainForRequest(c); d != nil && d.SSOProviderID > 0 &&
+		p.Frontend == models.SSOFrontendCustomer && p.ID != d.SSOProviderID {
+		c.String(http.StatusNotFound, "Unknown sign-in provider")
+		return nil, nil, false
+	}
+	return db, p, true
+}
+
+// ssoFail sends the browser back to the login page with a message.
+func ssoFail(c *gin.Context, p *models.SSOProvider, message string, err error) {
+	if err != nil {
+		log.Printf("sso: provider %d (%s) login failed: %v", p.ID, p.Name, err)
+	}
+	c.Redirect(http.StatusFound, ssoLoginPath(p.Frontend)+"?error="+url.QueryEscape(message))
+}
+
+// handleSSOLogin sends the browser to the identity provider.
+func handleSSOLogin(c *gin.Context) {
+	_, p, ok := ssoLoginProvider(c, "")
+	if !ok {
+		return
+	}
+
+	state, err := auth.NewSSOState()
+	if err != nil {
+		ssoFail(c, p, "Single sign-on is unavailable", err)
+		return
+	}
+	pending := auth.SSOPendingLogin{ProviderID: p.ID}
+	var target string
+
+	switch p.Protocol {
+	case models.SSOProtocolOIDC:
+		if pending.Verifier, err = auth.NewSSOState(); err == nil {
+			if pending.Nonce, err = auth.NewSSOState(); err == nil {
+				target, err = ssoOIDCClient(c, p).AuthCodeURL(c.Request.Context(), state, pending.Nonce, pending.Verifier)
+			}
+		}
+	case models.SSOProtocolSAML:
+		var sp *auth.SAMLServiceProvider
+		if sp, err = ssoSAMLProvider(c, p); err == nil {
+			target, pending.RequestID, err = sp.AuthnRequestURL(state)
+		}
+	}
+	if err != nil || target == "" {
+		ssoFail(c, p, "Single sign-on is unavailable", err)
+		return
+	}
+
+	// The OIDC callback is a top-level GET, which carries SameSite=Lax
+	// cookies. The SAML response is a cross-site POST, which only carries
+	// SameSite=None cookies, and browsers accept those over HTTPS only.
+	cookie := &http.Cookie{Name: ssoStateCookie, Value: state, Path: "/auth/sso/", MaxAge: int(auth.SSOLoginTTL.Seconds()),
+		HttpOnly: true, Secure: ssoSecureRequest(c), SameSite: http.SameSiteLaxMode}
+	pending.BrowserBound = true
+	if p.Protocol == models.SSOProtocolSAML {
+		cookie.SameSite = http.SameSiteNoneMode
+		pending.BrowserBound = cookie.Secure
+	}
+	if pending.BrowserBound {
+		http.SetCookie(c.Writer, cookie)
+	}
+
+	auth.GetSSOStateStore().Put(state, pending)
+	c.Redirect(http.StatusFound, target)
+}
+
+// takeSSOPending claims the pending login for state and checks that it
+// belongs to this provider and browser.
+func takeSSOPending(c *gin.Context, p *models.SSOProvider, state string) (auth.SSOPendingLogin, bool) {
+	http.SetCookie(c.Writer, &http.Cookie{Name: ssoStateCookie, Value: "", Path: "/auth/sso/", MaxAge: -1, HttpOnly: true})
+	if state == "" {
+		return auth.SSOPendingLogin{}, false
+	}
+	pending, ok := auth.GetSSOStateStore().Take(state)
+	if !ok || pending.ProviderID != p.ID {
+		return auth.SSOPendingLogin{}, false
+	}
+	if pending.BrowserBound {
+		cookie, err := c.Cookie(ssoStateCookie)
+		if err != nil || subtle.ConstantTimeCompare([]byte(cookie), []byte(state)) != 1 {
+			return auth.SSOPendingLogin{}, false
+		}
+	}
+	return pending, true
+}
+
+// handleSSOCallback completes an OpenID Connect login.
+func handleSSOCallback(c *gin.Context) {
+	db, p, ok := ssoLoginProvider(c, models.SSOProtocolOIDC)
+	if !ok {
+		return
+	}
+	pending, ok := takeSSOPending(c, p, c.Query("state"))
+	if !ok {
+		ssoFail(c, p, "The sign-in request expired. Please try again.", nil)
+		return
+	}
+	if e := c.Query("error"); e != "" {
+		ssoFail(c, p, "The identity provider did not sign you in", errors.New(e+": "+c.Query("error_description")))
+		return
+	}
+
+	claims, err := ssoOIDCClient(c, p).Exchange(c.Request.Context(), c.Query("code"), pending.Verifier, pending.Nonce)
+	if err != nil {
+		ssoFail(c, p, "The identity provider's response could not be verified", err)
+		return
+	}
+	attrs := make(map[string][]string, len(claims))
+	for k, v := range claims {
+		attrs[k] = auth.ClaimValues(v)
+	}
+	subject, _ := claims["sub"].(string)
+	finishSSOLogin(c, db, p, subject, attrs)
+}
+
+// handleSSOACS is the SAML assertion consumer service (HTTP-POST binding).
+func handleSSOACS(c *gin.Context) {
+	db, p, ok := ssoLoginProvider(c, models.SSOProtocolSAML)
+	if !ok {
+		return
+	}
+	pending, ok := takeSSOPending(c, p, c.PostForm("RelayState"))
+	if !ok {
+		ssoFail(c, p, "The sign-in request expired. Please try again.", nil)
+		return
+	}
+	sp, err := ssoSAMLProvider(c, p)
+	if err != nil {
+		ssoFail(c, p, "Single sign-on is unavailable", err)
+		return
+	}
+	assertion, err := sp.ParseResponse(c.PostForm("SAMLResponse"), pending.RequestID)
+	if err != nil {
+		ssoFail(c, p, "The identity provider's response could not be verified", err)
+		return
+	}
+	finishSSOLogin(c, db, p, assertion.NameID, assertion.Attributes)
+}
+
+// handleSSOMetadata serves the SAML service provider metadata to register
+// with the IdP. It is available while the provider is disabled so it can be
+// set up before going live.
+func handleSSOMetadata(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	db, dbErr := database.GetDB()
+	if err != nil || dbErr != nil || db == nil {
+		c.String(http.StatusNotFound, "Unknown sign-in provider")
+		return
+	}
+	p, err := service.NewSSOService(db).GetProvider(c.Request.Context(), id)
+	if err != nil || p.Protocol != models.SSOProtocolSAML {
+		c.String(http.StatusNotFound, "Unknown sign-in provider")
+		return
+	}
+	sp, err := ssoSAMLProvider(c, p)
+	if err != nil {
+		c.String(http.StatusConflict, "Provider configuration is incomplete")
+		return
+	}
+	c.Data(http.StatusOK, "application/samlmetadata+xml", sp.Metadata())
+}
+
+// finishSSOLogin maps the asserted identity to an account and signs it in.
+// Accounts with a local second factor still have to pass it.
+func finishSSOLogin(c *gin.Context, db *sql.DB, p *models.SSOProvider, subject string, attrs map[string][]string) {
+	svc := service.NewSSOService(db)
+	identity, err := service.IdentityFromClaims(p, subject, attrs)
+	if err != nil {
+		ssoFail(c, p, err.Error(), err)
+		return
+	}
+
+	if p.Frontend == models.SSOFrontendCustomer {
+		provider := p
+		if d := portalDomainForRequest(c); d != nil {
+			// Customers created on a portal domain join the domain's company
+			domainProvider := *p
+			domainProvider.Config.DefaultCustomerID = d.CustomerID
+			provider = &domainProvider
+		}
+		login, err := svc.ProvisionCustomer(c.Request.Context(), provider, identity)
+		if err != nil {
+			ssoFail(c, p, ssoAccountError(err), err)
+			return
+		}
+		if !portalDomainAllowsCustomer(c, login) {
+			ssoFail(c, p, "Your account cannot be used on this portal", fmt.Errorf("customer %s is not in the portal's company", login))
+			return
+		}
+		if message := maintenanceLoginBlock(c.Request.Context(), db, service.PasswordAccountCustomer, 0); message != "" {
+			ssoFail(c, p, message, nil)
+			return
+		}
+		log.Printf("sso: customer %s signed in via %s", login, p.Name)
+		startCustomerSSOSession(c, db, login)
+		return
+	}
+
+	userID, err := svc.ProvisionAgent(c.Request.Context(), p, identity)
+	if err != nil {
+		ssoFail(c, p, ssoAccountError(err), err)
+		return
+	}
+	if message := maintenanceLoginBlock(c.Request.Context(), db, service.PasswordAccountAgent, userID); message != "" {
+		ssoFail(c, p, message, nil)
+		return
+	}
+	log.Printf("sso: agent %s signed in via %s", identity.Login, p.Name)
+	if agentHasSecondFactor(db, userID) {
+		token, err := auth.GetTOTPSessionManager().CreateAgentSession(userID, identity.Login, c.ClientIP(), c.Request.UserAgent())
+		if err != nil {
+			ssoFail(c, p, "Failed to create 2FA session", err)
+			return
+		}
+		c.SetCookie("2fa_pending", token, 300, "/", "", false, true)
+		c.Redirect(http.StatusFound, "/login/2fa")
+		return
+	}
+	startAgentSession(c, shared.GetJWTManager(), db, userID, identity.Login, false)
+}
+
+// ssoAccountError turns provisioning errors into a message for the login page.
+func ssoAccountError(err error) string {
+	switch {
+	case errors.Is(err, service.ErrSSOUnknownAccount), errors.Is(err, service.ErrSSOAccountDisabled),
+		errors.Is(err, service.ErrSSONoLogin), errors.Is(err, service.ErrSSONoSubject),
+		errors.Is(err, service.ErrSSOAccountNotLinked):
+		return err.Error()
+	}
+	return "Signing in failed"
+}
+
+// startCustomerSSOSession issues the portal session for a customer user
+// signed in by an identity provider, as the password login does.
+func startCustomerSSOSession(c *gin.Context, db *sql.DB, login string) {
+	if customerHasSecondFactor(db, login) {
+		token, err := auth.GetTOTPSessionManager().CreateCustomerSession(login, c.ClientIP(), c.Request.UserAgent())
+		if err != nil {
+			c.Redirect(http.StatusFound, "/customer/login?error="+url.QueryEscape("Failed to create 2FA session"))
+			return
+		}
+		c.SetCookie("customer_2fa_pending", token, 300, "/", "", false, true)
+		c.Redirect(http.StatusFound, "/customer/login/2fa")
+		return
+	}
+
+	jwtManager := shared.GetJWTManager()
+	var userID uint
+	var email string
+	err := db.QueryRowContext(c.Request.Context(), database.ConvertPlaceholders(
+		"SELECT id, email FROM customer_user WHERE login = ?"), login).Scan(&userID, &email)
+	if err != nil || jwtManager == nil {
+		c.Redirect(http.StatusFound, "/customer/login?error="+url.QueryEscape("Signing in failed"))
+		return
+	}
+	tenantID := middleware.ResolveTenantFromHost(c.Request.Host)
+	token, err := jwtManager.GenerateTokenWithLogin(userID, login, email, "Customer", false, tenantID)
+	if err != nil {
+		c.Redirect(http.StatusFound, "/customer/login?error="+url.QueryEscape("Signing in failed"))
+		return
+	}
+
+	sessionTimeout := constants.DefaultSessionTimeout
+	c.SetCookie("customer_access_token", token, sessionTimeout, "/", "", false, true)
+	c.SetCookie("customer_auth_token", token, sessionTimeout, "/", "", false, true)
+	c.SetCookie("goatflow_customer_logged_in", "1", sessionTimeout, "/", "", false, false)
+
+	prefService := service.NewCustomerPreferencesService(db)
+	if userTheme := prefService.GetTheme(login); userTheme != "" {
+		c.SetCookie("goatflow_theme", userTheme, sessionTimeout, "/", "", false, false)
+	}
+	if userThemeMode := prefService.GetThemeMode(login); userThemeMode != "" {
+		c.SetCookie("goatflow_mode", userThemeMode, sessionTimeout, "/", "", false, false)
+	}
+
+	if sessionSvc := shared.GetSessionService(); sessionSvc != nil {
+		sessionID, err := sessionSvc.CreateSession(int(userID), login, "Customer", c.ClientIP(), c.Request.UserAgent())
+		if err != nil {
+			log.Printf("Failed to create customer session record: %v", err)
+		} else {
+			c.SetCookie("customer_session_id", sessionID, sessionTimeout, "/", "", false, true)
+		}
+	}
+
+	c.Redirect(http.StatusFound, "/customer")
+}
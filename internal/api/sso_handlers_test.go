@@ -0,0 +1,268 @@
+package api
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"database/sql"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/auth"
+	"github.com/goatkit/goatflow/internal/testutil"
+)
+
+func newSSOTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db := testutil.UseMigratedDB(t)
+	_, err := db.Exec(`INSERT INTO users (id, login, pw, first_name, last_name, valid_id, create_time, create_by, change_time, change_by)
+		VALUES (1, 'root@localhost', 'x', 'Admin', 'OTRS', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`)
+	require.NoError(t, err)
+	return db
+}
+
+func ssoTestCertificate(t *testing.T) string {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "idp.example.com"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+}
+
+func newSSOTestRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/auth/sso/:id/login", handleSSOLogin)
+	router.GET("/auth/sso/:id/callback", handleSSOCallback)
+	router.POST("/auth/sso/:id/acs", handleSSOACS)
+	router.GET("/auth/sso/:id/metadata", handleSSOMetadata)
+	router.POST("/admin/api/sso", handleCreateSSOProvider)
+	router.GET("/admin/api/sso/:id", handleAdminSSOProviderGet)
+	router.PUT("/admin/api/sso/:id", handleUpdateSSOProvider)
+	router.DELETE("/admin/api/sso/:id", handleDeleteSSOProvider)
+	router.GET("/admin/api/sso/:id/links", handleListSSOLinks)
+	router.POST("/admin/api/sso/:id/links", handleCreateSSOLink)
+	router.DELETE("/admin/api/sso/:id/links", handleDeleteSSOLink)
+	return router
+}
+
+func ssoRequest(router *gin.Engine, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if strings.HasPrefix(body, "{") {
+		req.Header.Set("Content-Type", "application/json")
+	} else if body != "" {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestSSOProviderAdminAPI(t *testing.T) {
+	db := newSSOTestDB(t)
+	router := newSSOTestRouter()
+
+	w := ssoRequest(router, http.MethodPost, "/admin/api/sso", `{"name": "Company Login", "protocol": "oidc", "frontend": "agent",
+		"config": {"issuer": "https://login.example.com", "client_id": "goatflow", "client_secret": "s3cret"},
+		"group_mappings": [{"value": "support", "group_id": 3, "permission": "rw"}]}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	assert.NotContains(t, w.Body.String(), "s3cret")
+
+	w = ssoRequest(router, http.MethodGet, "/admin/api/sso/1", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"client_secret":"********"`)
+
+	t.Run("placeholder keeps the secret", func(t *testing.T) {
+		w := ssoRequest(router, http.MethodPut, "/admin/api/sso/1", `{"name": "Company Login", "protocol": "oidc", "frontend": "agent",
+			"config": {"issuer": "https://login.example.com", "client_id": "goatflow", "client_secret": "********"}}`)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var stored string
+		require.NoError(t, db.QueryRow("SELECT config FROM sso_provider WHERE id = 1").Scan(&stored))
+		assert.Contains(t, stored, "s3cret")
+	})
+
+	for name, tc := range map[string]struct {
+		method, path, body string
+		code               int
+	}{
+		"duplicate name":     {http.MethodPost, "/admin/api/sso", `{"name": "Company Login", "protocol": "oidc", "frontend": "agent", "config": {"issuer": "https://x", "client_id": "x"}}`, http.StatusConflict},
+		"unknown protocol":   {http.MethodPost, "/admin/api/sso", `{"name": "CAS", "protocol": "cas", "frontend": "agent"}`, http.StatusBadRequest},
+		"incomplete config":  {http.MethodPost, "/admin/api/sso", `{"name": "Okta", "protocol": "oidc", "frontend": "agent"}`, http.StatusBadRequest},
+		"missing name":       {http.MethodPost, "/admin/api/sso", `{"protocol": "oidc", "frontend": "agent"}`, http.StatusBadRequest},
+		"invalid id":         {http.MethodGet, "/admin/api/sso/abc", "", http.StatusBadRequest},
+		"missing provider":   {http.MethodGet, "/admin/api/sso/99", "", http.StatusNotFound},
+		"delete missing":     {http.MethodDelete, "/admin/api/sso/99", "", http.StatusNotFound},
+		"customer owner map": {http.MethodPost, "/admin/api/sso", `{"name": "Portal", "protocol": "oidc", "frontend": "customer", "config": {"issuer": "https://x", "client_id": "x"}, "group_mappings": [{"value": "a", "group_id": 1, "permission": "owner"}]}`, http.StatusBadRequest},
+	} {
+		t.Run(name, func(t *testing.T) {
+			w := ssoRequest(router, tc.method, tc.path, tc.body)
+			assert.Equal(t, tc.code, w.Code, w.Body.String())
+		})
+	}
+
+	w = ssoRequest(router, http.MethodDelete, "/admin/api/sso/1", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestSSOLinkAdminAPI(t *testing.T) {
+	newSSOTestDB(t)
+	router := newSSOTestRouter()
+	require.Equal(t, http.StatusCreated, ssoRequest(router, http.MethodPost, "/admin/api/sso", `{"name": "Company Login", "protocol": "oidc",
+		"frontend": "agent", "config": {"issuer": "https://login.example.com", "client_id": "goatflow"}}`).Code)
+
+	w := ssoRequest(router, http.MethodPost, "/admin/api/sso/1/links", `{"subject": "00u1abc", "login": "root@localhost"}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"user_id":1`)
+
+	w = ssoRequest(router, http.MethodGet, "/admin/api/sso/1/links", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"subject":"00u1abc"`)
+	assert.Contains(t, w.Body.String(), `"login":"root@localhost"`)
+
+	for name, tc := range map[string]struct {
+		method, path, body string
+		code               int
+	}{
+		"subject already linked": {http.MethodPost, "/admin/api/sso/1/links", `{"subject": "00u1abc", "login": "root@localhost"}`, http.StatusConflict},
+		"unknown account":        {http.MethodPost, "/admin/api/sso/1/links", `{"subject": "00u2def", "login": "nobody"}`, http.StatusBadRequest},
+		"missing login":          {http.MethodPost, "/admin/api/sso/1/links", `{"subject": "00u2def"}`, http.StatusBadRequest},
+		"unknown provider":       {http.MethodPost, "/admin/api/sso/99/links", `{"subject": "00u2def", "login": "root@localhost"}`, http.StatusNotFound},
+		"unlink without subject": {http.MethodDelete, "/admin/api/sso/1/links", "", http.StatusBadRequest},
+		"unlink unknown subject": {http.MethodDelete, "/admin/api/sso/1/links?subject=00u2def", "", http.StatusNotFound},
+	} {
+		t.Run(name, func(t *testing.T) {
+			w := ssoRequest(router, tc.method, tc.path, tc.body)
+			assert.Equal(t, tc.code, w.Code, w.Body.String())
+		})
+	}
+
+	w = ssoRequest(router, http.MethodDelete, "/admin/api/sso/1/links?subject=00u1abc", "")
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = ssoRequest(router, http.MethodGet, "/admin/api/sso/1/links", "")
+	assert.NotContains(t, w.Body.String(), "00u1abc")
+}
+
+func TestSSOLoginFlow(t *testing.T) {
+	newSSOTestDB(t)
+	router := newSSOTestRouter()
+	certJSON, err := json.Marshal(ssoTestCertificate(t))
+	require.NoError(t, err)
+
+	body := `{"name": "Corporate IdP", "protocol": "saml", "frontend": "customer", "config": {"idp_sso_url": "https://idp.example.com/sso",
+		"idp_entity_id": "https://idp.example.com", "idp_certificate": ` + string(certJSON) + `}}`
+	require.Equal(t, http.StatusCreated, ssoRequest(router, http.MethodPost, "/admin/api/sso", body).Code)
+	require.Equal(t, http.StatusCreated, ssoRequest(router, http.MethodPost, "/admin/api/sso", `{"name": "Retired", "protocol": "oidc",
+		"frontend": "agent", "valid_id": 2, "config": {"issuer": "https://old.example.com", "client_id": "x"}}`).Code)
+
+	t.Run("unknown and disabled providers", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, ssoRequest(router, http.MethodGet, "/auth/sso/99/login", "").Code)
+		assert.Equal(t, http.StatusNotFound, ssoRequest(router, http.MethodGet, "/auth/sso/2/login", "").Code)
+		assert.Equal(t, http.StatusNotFound, ssoRequest(router, http.MethodGet, "/auth/sso/x/login", "").Code)
+	})
+
+	t.Run("protocol endpoints are not interchangeable", func(t *testing.T) {
+		assert.Equal(t, http.StatusNotFound, ssoRequest(router, http.MethodGet, "/auth/sso/1/callback?state=x&code=y", "").Code)
+		assert.Equal(t, http.StatusNotFound, ssoRequest(router, http.MethodGet, "/auth/sso/2/metadata", "").Code)
+	})
+
+	t.Run("metadata", func(t *testing.T) {
+		w := ssoRequest(router, http.MethodGet, "/auth/sso/1/metadata", "")
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `Location="http://example.com/auth/sso/1/acs"`)
+		assert.Contains(t, w.Body.String(), `entityID="http://example.com/auth/sso/1/metadata"`)
+	})
+
+	w := ssoRequest(router, http.MethodGet, "/auth/sso/1/login", "")
+	require.Equal(t, http.StatusFound, w.Code)
+	target, err := url.Parse(w.Header().Get("Location"))
+	require.NoError(t, err)
+	assert.Equal(t, "idp.example.com", target.Host)
+	assert.NotEmpty(t, target.Query().Get("SAMLRequest"))
+	relayState := target.Query().Get("RelayState")
+	require.NotEmpty(t, relayState)
+	assert.Empty(t, w.Header().Get("Set-Cookie"), "SameSite=None cookies need HTTPS")
+
+	t.Run("unknown relay state", func(t *testing.T) {
+		w := ssoRequest(router, http.MethodPost, "/auth/sso/1/acs", url.Values{"RelayState": {"forged"}, "SAMLResponse": {"x"}}.Encode())
+		assert.Equal(t, http.StatusFound, w.Code)
+		assert.True(t, strings.HasPrefix(w.Header().Get("Location"), "/customer/login?error="))
+	})
+
+	t.Run("invalid response", func(t *testing.T) {
+		w := ssoRequest(router, http.MethodPost, "/auth/sso/1/acs", url.Values{"RelayState": {relayState}, "SAMLResponse": {"PHg+"}}.Encode())
+		assert.Equal(t, http.StatusFound, w.Code)
+		assert.Contains(t, w.Header().Get("Location"), "could+not+be+verified")
+	})
+
+	t.Run("state is single use", func(t *testing.T) {
+		_, ok := auth.GetSSOStateStore().Take(relayState)
+		assert.False(t, ok)
+	})
+}
+
+func TestSSOStateBoundToBrowser(t *testing.T) {
+	newSSOTestDB(t)
+	router := newSSOTestRouter()
+	require.Equal(t, http.StatusCreated, ssoRequest(router, http.MethodPost, "/admin/api/sso", `{"name": "Company Login", "protocol": "oidc",
+		"frontend": "agent", "config": {"issuer": "https://login.example.com", "client_id": "goatflow"}}`).Code)
+
+	auth.GetSSOStateStore().Put("state-1", auth.SSOPendingLogin{ProviderID: 1, Verifier: "v", Nonce: "n", BrowserBound: true})
+	auth.GetSSOStateStore().Put("state-2", auth.SSOPendingLogin{ProviderID: 7, BrowserBound: true})
+
+	t.Run("missing cookie", func(t *testing.T) {
+		w := ssoRequest(router, http.MethodGet, "/auth/sso/1/callback?state=state-1&code=abc", "")
+		assert.Equal(t, http.StatusFound, w.Code)
+		assert.Equal(t, "/login?error=The+sign-in+request+expired.+Please+try+again.", w.Header().Get("Location"))
+		_, ok := auth.GetSSOStateStore().Take("state-1")
+		assert.False(t, ok, "a failed attempt consumes the state")
+	})
+
+	t.Run("other provider's state", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/auth/sso/1/callback?state=state-2&code=abc", nil)
+		req.AddCookie(&http.Cookie{Name: ssoStateCookie, Value: "state-2"})
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusFound, w.Code)
+		assert.Contains(t, w.Header().Get("Location"), "/login?error=")
+	})
+}
+
+func TestSSOBaseURL(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	for _, tc := range []struct {
+		proto string
+		want  string
+	}{
+		{"", "http://helpdesk.example.com"},
+		{"https", "https://helpdesk.example.com"},
+		{"HTTPS", "https://helpdesk.example.com"},
+	} {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodGet, "http://helpdesk.example.com/auth/sso/1/login", nil)
+		if tc.proto != "" {
+			c.Request.Header.Set("X-Forwarded-Proto", tc.proto)
+		}
+		assert.Equal(t, tc.want, ssoBaseURL(c))
+	}
+}
@@ -0,0 +1,346 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/service"
+)
+
+// surveyRequest is the JSON body accepted by the survey create/update
+// handlers.
+type surveyRequest struct {
+	Name             string                  `json:"name" binding:"required"`
+	Description      string                  `json:"description"`
+	Questions        []models.SurveyQuestion `json:"questions" binding:"required"`
+	QueueIDs         []int                   `json:"queue_ids"`
+	SendDelayMinutes int                     `json:"send_delay_minutes"`
+	LinkValidDays    int                     `json:"link_valid_days"`
+	EmailSubject     string                  `json:"email_subject"`
+	EmailBody        string                  `json:"email_body"`
+	ValidID          int                     `json:"valid_id"`
+}
+
+func (r *surveyRequest) survey() *models.Survey {
+	return &models.Survey{
+		Name:             r.Name,
+		Description:      r.Description,
+		Questions:        r.Questions,
+		QueueIDs:         r.QueueIDs,
+		SendDelayMinutes: r.SendDelayMinutes,
+		LinkValidDays:    r.LinkValidDays,
+		EmailSubject:     r.EmailSubject,
+		EmailBody:        r.EmailBody,
+		ValidID:          r.ValidID,
+	}
+}
+
+// surveyService returns the service, writing 503 when the database is
+// unavailable.
+func surveyService(c *gin.Context) *service.SurveyService {
+	db, err := database.GetDB()
+	if err != nil || db == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"success": false, "error": "Database unavailable"})
+		return nil
+	}
+	return service.NewSurveyService(db)
+}
+
+// surveyError maps SurveyService errors to responses.
+func surveyError(c *gin.Context, err error, action string) {
+	switch {
+	case errors.Is(err, service.ErrSurveyNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Survey not found"})
+	case errors.Is(err, service.ErrSurveyNameExists), errors.Is(err, service.ErrSurveyInUse):
+		c.JSON(http.StatusConflict, gin.H{"success": false, "error": err.Error()})
+	case errors.Is(err, service.ErrSurveyNameRequired),
+		errors.Is(err, service.ErrSurveyQuestions),
+		errors.Is(err, service.ErrSurveyEmail):
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+	default:
+		log.Printf("survey api: %s failed: %v", action, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to " + action})
+	}
+}
+
+// surveyID parses the :id path parameter, writing 400 when it is invalid.
+func surveyID(c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid survey ID"})
+		return 0, false
+	}
+	return id, true
+}
+
+// surveyPeriod parses the days query parameter (default 30, at most 366)
+// into the period ending now, writing 400 when it is invalid.
+func surveyPeriod(c *gin.Context) (time.Time, time.Time, bool) {
+	days, err := strconv.Atoi(c.DefaultQuery("days", "30"))
+	if err != nil || days <= 0 || days > 366 {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "days must be between 1 and 366"})
+		return time.Time{}, time.Time{}, false
+	}
+	to := time.Now()
+	return to.AddDate(0, 0, -days), to, true
+}
+
+// HandleListSurveysAPI handles GET /api/v1/admin/surveys.
+//
+//	@Summary		List satisfaction surveys
+//	@Tags			Surveys
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}	"Surveys, including disabled ones"
+//	@Security		BearerAuth
+//	@Router			/admin/surveys [get]
+func HandleListSurveysAPI(c *gin.Context) {
+	svc := surveyService(c)
+	if svc == nil {
+		return
+	}
+	list, err := svc.List(c.Request.Context())
+	if err != nil {
+		surveyError(c, err, "load surveys")
+		return
+	}
+	if list == nil {
+		list = []*models.Survey{}
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": list})
+}
+
+// HandleCreateSurveyAPI handles POST /api/v1/admin/surveys.
+//
+//	@Summary		Create satisfaction survey
+//	@Description	Valid surveys are emailed to the customers of tickets closed after the survey was created.
+//	@Tags			Surveys
+//	@Accept			json
+//	@Produce		json
+//	@Param			survey	body		object	true	"Survey (name, questions, queue_ids, send_delay_minutes, link_valid_days, email_subject, email_body, valid_id)"
+//	@Success		201		{object}	map[string]interface{}	"Survey created"
+//	@Failure		400		{object}	map[string]interface{}	"Invalid request"
+//	@Failure		409		{object}	map[string]interface{}	"Name already in use"
+//	@Security		BearerAuth
+//	@Router			/admin/surveys [post]
+func HandleCreateSurveyAPI(c *gin.Context) {
+	var req surveyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid survey request: " + err.Error()})
+		return
+	}
+	survey := req.survey()
+	// Reject bad questions before touching the database
+	if err := service.ValidateSurveyQuestions(survey.Questions); err != nil {
+		surveyError(c, err, "create survey")
+		return
+	}
+	svc := surveyService(c)
+	if svc == nil {
+		return
+	}
+	if err := svc.Create(c.Request.Context(), survey, GetUserIDFromCtx(c, 1)); err != nil {
+		surveyError(c, err, "create survey")
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"success": true, "data": survey})
+}
+
+// HandleGetSurveyAPI handles GET /api/v1/admin/surveys/:id.
+//
+//	@Summary		Get satisfaction survey
+//	@Tags			Surveys
+//	@Produce		json
+//	@Param			id	path		int	true	"Survey ID"
+//	@Success		200	{object}	map[string]interface{}	"Survey"
+//	@Failure		404	{object}	map[string]interface{}	"Survey not found"
+//	@Security		BearerAuth
+//	@Router			/admin/surveys/{id} [get]
+func HandleGetSurveyAPI(c *gin.Context) {
+	id, ok := surveyID(c)
+	if !ok {
+		return
+	}
+	svc := surveyService(c)
+	if svc == nil {
+		return
+	}
+	survey, err := svc.Get(c.Request.Context(), id)
+	if err != nil {
+		surveyError(c, err, "load survey")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": survey})
+}
+
+// HandleUpdateSurveyAPI handles PUT /api/v1/admin/surveys/:id.
+//
+//	@Summary		Update satisfaction survey
+//	@Description	Links already sent stay valid; their answers are checked against the updated questions.
+//	@Tags			Surveys
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int		true	"Survey ID"
+//	@Param			survey	body		object	true	"Survey"
+//	@Success		200		{object}	map[string]interface{}	"Survey updated"
+//	@Failure		400		{object}	map[string]interface{}	"Invalid request"
+//	@Failure		404		{object}	map[string]interface{}	"Survey not found"
+//	@Security		BearerAuth
+//	@Router			/admin/surveys/{id} [put]
+func HandleUpdateSurveyAPI(c *gin.Context) {
+	id, ok := surveyID(c)
+	if !ok {
+		return
+	}
+	var req surveyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid survey request: " + err.Error()})
+		return
+	}
+	survey := req.survey()
+	survey.ID = id
+	if err := service.ValidateSurveyQuestions(survey.Questions); err != nil {
+		surveyError(c, err, "update survey")
+		return
+	}
+	svc := surveyService(c)
+	if svc == nil {
+		return
+	}
+	if err := svc.Update(c.Request.Context(), survey, GetUserIDFromCtx(c, 1)); err != nil {
+		surveyError(c, err, "update survey")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": survey})
+}
+
+// HandleDeleteSurveyAPI handles DELETE /api/v1/admin/surveys/:id.
+//
+//	@Summary		Delete satisfaction survey
+//	@Description	Only surveys that were never sent can be deleted; disable the others to keep their results.
+//	@Tags			Surveys
+//	@Produce		json
+//	@Param			id	path		int	true	"Survey ID"
+//	@Success		200	{object}	map[string]interface{}	"Survey deleted"
+//	@Failure		404	{object}	map[string]interface{}	"Survey not found"
+//	@Failure		409	{object}	map[string]interface{}	"Survey was already sent"
+//	@Security		BearerAuth
+//	@Router			/admin/surveys/{id} [delete]
+func HandleDeleteSurveyAPI(c *gin.Context) {
+	id, ok := surveyID(c)
+	if !ok {
+		return
+	}
+	svc := surveyService(c)
+	if svc == nil {
+		return
+	}
+	if err := svc.Delete(c.Request.Context(), id); err != nil {
+		surveyError(c, err, "delete survey")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Survey deleted"})
+}
+
+// HandleGetSurveyStatsAPI handles GET /api/v1/admin/surveys/:id/stats.
+//
+//	@Summary		Get satisfaction survey results
+//	@Description	Response rate, average rating, NPS and per-question results of the surveys sent in the period.
+//	@Tags			Surveys
+//	@Produce		json
+//	@Param			id		path		int	true	"Survey ID"
+//	@Param			days	query		int	false	"Period in days, ending now (default 30)"
+//	@Success		200		{object}	map[string]interface{}	"Survey results"
+//	@Failure		404		{object}	map[string]interface{}	"Survey not found"
+//	@Security		BearerAuth
+//	@Router			/admin/surveys/{id}/stats [get]
+func HandleGetSurveyStatsAPI(c *gin.Context) {
+	id, ok := surveyID(c)
+	if !ok {
+		return
+	}
+	from, to, ok := surveyPeriod(c)
+	if !ok {
+		return
+	}
+	svc := surveyService(c)
+	if svc == nil {
+		return
+	}
+	stats, err := svc.Stats(c.Request.Context(), id, from, to)
+	if err != nil {
+		surveyError(c, err, "load survey results")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": stats})
+}
+
+// HandleListSurveyResponsesAPI handles GET /api/v1/admin/surveys/:id/responses.
+//
+//	@Summary		List satisfaction survey responses
+//	@Tags			Surveys
+//	@Produce		json
+//	@Param			id		path		int	true	"Survey ID"
+//	@Param			limit	query		int	false	"Maximum entries (default 50)"
+//	@Param			offset	query		int	false	"Offset"
+//	@Success		200		{object}	map[string]interface{}	"Answered surveys with their answers, newest first"
+//	@Failure		404		{object}	map[string]interface{}	"Survey not found"
+//	@Security		BearerAuth
+//	@Router			/admin/surveys/{id}/responses [get]
+func HandleListSurveyResponsesAPI(c *gin.Context) {
+	id, ok := surveyID(c)
+	if !ok {
+		return
+	}
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if limit <= 0 || limit > 500 {
+		limit = 50
+	}
+	offset, _ := strconv.Atoi(c.Query("offset"))
+	if offset < 0 {
+		offset = 0
+	}
+	svc := surveyService(c)
+	if svc == nil {
+		return
+	}
+	ctx := c.Request.Context()
+	if _, err := svc.Get(ctx, id); err != nil {
+		surveyError(c, err, "load survey responses")
+		return
+	}
+	list, err := svc.ListResponses(ctx, id, limit, offset)
+	if err != nil {
+		surveyError(c, err, "load survey responses")
+		return
+	}
+	if list == nil {
+		list = []*models.SurveyRequest{}
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": list})
+}
+
+// handleDashboardSatisfaction returns the results of all surveys for the
+// customer satisfaction dashboard widget.
+func handleDashboardSatisfaction(c *gin.Context) {
+	from, to, ok := surveyPeriod(c)
+	if !ok {
+		return
+	}
+	svc := surveyService(c)
+	if svc == nil {
+		return
+	}
+	stats, err := svc.Stats(c.Request.Context(), 0, from, to)
+	if err != nil {
+		surveyError(c, err, "load satisfaction")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": stats})
+}
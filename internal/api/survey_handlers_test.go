@@ -0,0 +1,75 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandleCreateSurveyAPI_Validation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	for name, tc := range map[string]struct {
+		body string
+		want string
+	}{
+		"missing name":      {`{"questions": [{"id": "overall", "type": "rating", "label": "How did we do?"}]}`, "Invalid survey request"},
+		"missing questions": {`{"name": "After close"}`, "Invalid survey request"},
+		"malformed json":    {`{"name": `, "Invalid survey request"},
+		"no questions":      {`{"name": "After close", "questions": []}`, "invalid survey questions"},
+		"bad question id":   {`{"name": "After close", "questions": [{"id": "How?", "type": "rating", "label": "x"}]}`, "question id"},
+		"unknown type":      {`{"name": "After close", "questions": [{"id": "overall", "type": "stars", "label": "x"}]}`, "unknown type"},
+		"choice w/o opts":   {`{"name": "After close", "questions": [{"id": "channel", "type": "choice", "label": "x"}]}`, "at least two options"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			router := gin.New()
+			router.POST("/api/v1/admin/surveys", HandleCreateSurveyAPI)
+
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/admin/surveys", strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Contains(t, w.Body.String(), tc.want)
+		})
+	}
+}
+
+func TestSurveyHandlers_InvalidRequest(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.GET("/api/v1/admin/surveys/:id", HandleGetSurveyAPI)
+	router.PUT("/api/v1/admin/surveys/:id", HandleUpdateSurveyAPI)
+	router.DELETE("/api/v1/admin/surveys/:id", HandleDeleteSurveyAPI)
+	router.GET("/api/v1/admin/surveys/:id/stats", HandleGetSurveyStatsAPI)
+	router.GET("/api/v1/admin/surveys/:id/responses", HandleListSurveyResponsesAPI)
+	router.GET("/api/dashboard/satisfaction", handleDashboardSatisfaction)
+
+	for _, tc := range []struct {
+		method string
+		path   string
+		want   string
+	}{
+		{http.MethodGet, "/api/v1/admin/surveys/abc", "Invalid survey ID"},
+		{http.MethodPut, "/api/v1/admin/surveys/0", "Invalid survey ID"},
+		{http.MethodDelete, "/api/v1/admin/surveys/-1", "Invalid survey ID"},
+		{http.MethodGet, "/api/v1/admin/surveys/x/stats", "Invalid survey ID"},
+		{http.MethodGet, "/api/v1/admin/surveys/x/responses", "Invalid survey ID"},
+		{http.MethodGet, "/api/v1/admin/surveys/1/stats?days=0", "days must be between"},
+		{http.MethodGet, "/api/dashboard/satisfaction?days=1000", "days must be between"},
+	} {
+		t.Run(tc.method+" "+tc.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(tc.method, tc.path, nil))
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Contains(t, w.Body.String(), tc.want)
+		})
+	}
+}
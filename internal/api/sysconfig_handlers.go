@@ -0,0 +1,503 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/flosch/pongo2/v6"
+	"github.com/gin-gonic/gin"
+
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/service"
+)
+
+// sysconfigUpdateRequest is the JSON body of PUT /api/v1/admin/config.
+// Values may be strings or JSON scalars and arrays; they are converted to
+// their stored form before validation.
+type sysconfigUpdateRequest struct {
+	Settings map[string]interface{} `json:"settings"`
+	Reset    []string               `json:"reset"`
+}
+
+// sysconfigDeployRequest is the JSON body of a deployment or rollback.
+type sysconfigDeployRequest struct {
+	Comments string `json:"comments"`
+}
+
+// sysconfigService creates a sysconfig service, writing 503 when the
+// database is unavailable.
+func sysconfigService(c *gin.Context) *service.SysconfigService {
+	db, err := database.GetDB()
+	if err != nil || db == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"success": false, "error": "Database unavailable"})
+		return nil
+	}
+	return service.NewSysconfigService(db)
+}
+
+// sysconfigError maps SysconfigService errors to responses. Validation
+// failures carry the message of each rejected setting in errors.
+func sysconfigError(c *gin.Context, err error, action string) {
+	var verr *service.SysconfigValidationError
+	switch {
+	case errors.As(err, &verr):
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid settings", "errors": verr.Errors})
+	case errors.Is(err, service.ErrSysconfigNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Setting not found"})
+	case errors.Is(err, service.ErrSysconfigDeploymentNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Deployment not found"})
+	case errors.Is(err, service.ErrSysconfigDeploymentFormat):
+		c.JSON(http.StatusConflict, gin.H{"success": false, "error": err.Error()})
+	default:
+		log.Printf("sysconfig api: %s failed: %v", action, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to " + action})
+	}
+}
+
+// sysconfigFilter reads the list filter from the query string.
+func sysconfigFilter(c *gin.Context) service.SysconfigFilter {
+	modified, _ := strconv.ParseBool(c.Query("modified"))
+	return service.SysconfigFilter{
+		Navigation:   strings.TrimSpace(c.Query("navigation")),
+		Search:       strings.TrimSpace(c.Query("search")),
+		ModifiedOnly: modified,
+	}
+}
+
+// sysconfigDeploymentID parses the :id path parameter, writing 400 when it
+// is invalid.
+func sysconfigDeploymentID(c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid deployment ID"})
+		return 0, false
+	}
+	return id, true
+}
+
+// sysconfigRequestValue converts a JSON value to a setting value. Arrays
+// become JSON; numbers keep their JSON form so 3600 stays "3600".
+func sysconfigRequestValue(v interface{}) (string, error) {
+	switch val := v.(type) {
+	case string:
+		return val, nil
+	case bool:
+		return strconv.FormatBool(val), nil
+	case nil:
+		return "", nil
+	case []interface{}:
+		items := make([]string, len(val))
+		for i, item := range val {
+			s, ok := item.(string)
+			if !ok {
+				return "", fmt.Errorf("array items must be strings")
+			}
+			items[i] = s
+		}
+		data, err := json.Marshal(items)
+		return string(data), err
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64), nil
+	}
+	return "", fmt.Errorf("unsupported value")
+}
+
+// handleAdminSettings renders the system configuration editor.
+func handleAdminSettings(c *gin.Context) {
+	db, err := database.GetDB()
+	if err != nil || db == nil {
+		sendErrorResponse(c, http.StatusServiceUnavailable, "Database unavailable")
+		return
+	}
+	svc := service.NewSysconfigService(db)
+	ctx := c.Request.Context()
+	filter := sysconfigFilter(c)
+
+	settings, err := svc.List(ctx, filter)
+	if err != nil {
+		log.Printf("sysconfig: list settings failed: %v", err)
+		sendErrorResponse(c, http.StatusInternalServerError, "Failed to load settings")
+		return
+	}
+	navigations, err := svc.Navigations(ctx)
+	if err != nil {
+		log.Printf("sysconfig: list navigations failed: %v", err)
+		sendErrorResponse(c, http.StatusInternalServerError, "Failed to load settings")
+		return
+	}
+	diff, err := svc.Diff(ctx)
+	if err != nil {
+		log.Printf("sysconfig: diff failed: %v", err)
+		sendErrorResponse(c, http.StatusInternalServerError, "Failed to load settings")
+		return
+	}
+	pending := 0
+	for _, entry := range diff {
+		if entry.Pending {
+			pending++
+		}
+	}
+	deployments, err := svc.Deployments(ctx, 10)
+	if err != nil {
+		log.Printf("sysconfig: list deployments failed: %v", err)
+		sendErrorResponse(c, http.StatusInternalServerError, "Failed to load settings")
+		return
+	}
+
+	getPongo2Renderer().HTML(c, http.StatusOK, "pages/admin/sysconfig.pongo2", pongo2.Context{
+		"Title":        "System Configuration",
+		"Settings":     settings,
+		"Navigations":  navigations,
+		"Navigation":   filter.Navigation,
+		"SearchQuery":  filter.Search,
+		"ModifiedOnly": filter.ModifiedOnly,
+		"Diff":         diff,
+		"PendingCount": pending,
+		"Deployments":  deployments,
+		"User":         getUserMapForTemplate(c),
+		"ActivePage":   "admin",
+	})
+}
+
+// handleAdminSettingsHistory renders the deployment history with the
+// per-setting changes of the selected deployment.
+func handleAdminSettingsHistory(c *gin.Context) {
+	db, err := database.GetDB()
+	if err != nil || db == nil {
+		sendErrorResponse(c, http.StatusServiceUnavailable, "Database unavailable")
+		return
+	}
+	svc := service.NewSysconfigService(db)
+	ctx := c.Request.Context()
+
+	deployments, err := svc.Deployments(ctx, 50)
+	if err != nil {
+		log.Printf("sysconfig: list deployments failed: %v", err)
+		sendErrorResponse(c, http.StatusInternalServerError, "Failed to load deployments")
+		return
+	}
+
+	selected := 0
+	if id, err := strconv.Atoi(c.Query("id")); err == nil && id > 0 {
+		selected = id
+	} else if len(deployments) > 0 {
+		selected = deployments[0].ID
+	}
+	// Deployments made by OTRS cannot be read; they render without changes.
+	var diff *models.SysconfigDeploymentDiff
+	if selected > 0 {
+		diff, err = svc.DeploymentDiff(ctx, selected, 0)
+		switch {
+		case err == nil, errors.Is(err, service.ErrSysconfigDeploymentFormat):
+		case errors.Is(err, service.ErrSysconfigDeploymentNotFound):
+			sendErrorResponse(c, http.StatusNotFound, "Deployment not found")
+			return
+		default:
+			log.Printf("sysconfig: diff deployment %d failed: %v", selected, err)
+			sendErrorResponse(c, http.StatusInternalServerError, "Failed to load deployment")
+			return
+		}
+	}
+
+	getPongo2Renderer().HTML(c, http.StatusOK, "pages/admin/sysconfig_history.pongo2", pongo2.Context{
+		"Title":       "Deployment History",
+		"Deployments": deployments,
+		"SelectedID":  selected,
+		"Diff":        diff,
+		"User":        getUserMapForTemplate(c),
+		"ActivePage":  "admin",
+	})
+}
+
+// HandleListSysconfigAPI handles GET /api/v1/admin/config.
+//
+//	@Summary		List system configuration settings
+//	@Description	Returns the visible settings with their type, schema, default and effective value. Password values are withheld.
+//	@Tags			System Configuration
+//	@Produce		json
+//	@Param			navigation	query		string					false	"Navigation group, including its sub-groups"
+//	@Param			search		query		string					false	"Match on name or description"
+//	@Param			modified	query		bool					false	"Only settings that differ from their default"
+//	@Success		200			{object}	map[string]interface{}	"Settings"
+//	@Security		BearerAuth
+//	@Router			/admin/config [get]
+func HandleListSysconfigAPI(c *gin.Context) {
+	svc := sysconfigService(c)
+	if svc == nil {
+		return
+	}
+	settings, err := svc.List(c.Request.Context(), sysconfigFilter(c))
+	if err != nil {
+		sysconfigError(c, err, "load settings")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": settings})
+}
+
+// HandleUpdateSysconfigAPI handles PUT /api/v1/admin/config.
+//
+//	@Summary		Update system configuration settings
+//	@Description	Sets the given settings and resets the listed ones to their defaults. Every value is validated against its setting's schema; if one is rejected nothing is saved.
+//	@Tags			System Configuration
+//	@Accept			json
+//	@Produce		json
+//	@Param			changes	body		object					true	"settings (name to value) and reset (names)"
+//	@Success		200		{object}	map[string]interface{}	"Changed settings"
+//	@Failure		400		{object}	map[string]interface{}	"Invalid settings, with a message per setting in errors"
+//	@Security		BearerAuth
+//	@Router			/admin/config [put]
+func HandleUpdateSysconfigAPI(c *gin.Context) {
+	var req sysconfigUpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid request: " + err.Error()})
+		return
+	}
+	if len(req.Settings) == 0 && len(req.Reset) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "settings or reset is required"})
+		return
+	}
+	values := make(map[string]string, len(req.Settings))
+	invalid := map[string]string{}
+	for name, raw := range req.Settings {
+		v, err := sysconfigRequestValue(raw)
+		if err != nil {
+			invalid[name] = err.Error()
+			continue
+		}
+		values[name] = v
+	}
+	if len(invalid) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid settings", "errors": invalid})
+		return
+	}
+
+	svc := sysconfigService(c)
+	if svc == nil {
+		return
+	}
+	changed, err := svc.Update(c.Request.Context(), values, req.Reset, GetUserIDFromCtx(c, 1))
+	if err != nil {
+		sysconfigError(c, err, "update settings")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": changed})
+}
+
+// HandleSysconfigDiffAPI handles GET /api/v1/admin/config/diff.
+//
+//	@Summary		Diff system configuration
+//	@Description	Returns the settings that differ from their default or changed since the last deployment. pending marks the changes the next deployment would record.
+//	@Tags			System Configuration
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}	"Changed settings"
+//	@Security		BearerAuth
+//	@Router			/admin/config/diff [get]
+func HandleSysconfigDiffAPI(c *gin.Context) {
+	svc := sysconfigService(c)
+	if svc == nil {
+		return
+	}
+	diff, err := svc.Diff(c.Request.Context())
+	if err != nil {
+		sysconfigError(c, err, "diff settings")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": diff})
+}
+
+// HandleListSysconfigDeploymentsAPI handles GET /api/v1/admin/config/deployments.
+//
+//	@Summary		List configuration deployments
+//	@Description	Returns the most recent deployments, newest first, without their settings.
+//	@Tags			System Configuration
+//	@Produce		json
+//	@Param			limit	query		int						false	"Maximum number of deployments (default 20, at most 100)"
+//	@Success		200		{object}	map[string]interface{}	"Deployments"
+//	@Security		BearerAuth
+//	@Router			/admin/config/deployments [get]
+func HandleListSysconfigDeploymentsAPI(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	svc := sysconfigService(c)
+	if svc == nil {
+		return
+	}
+	deployments, err := svc.Deployments(c.Request.Context(), limit)
+	if err != nil {
+		sysconfigError(c, err, "load deployments")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": deployments})
+}
+
+// HandleCreateSysconfigDeploymentAPI handles POST /api/v1/admin/config/deployments.
+//
+//	@Summary		Deploy system configuration
+//	@Description	Records a versioned snapshot of every effective setting value.
+//	@Tags			System Configuration
+//	@Accept			json
+//	@Produce		json
+//	@Param			deployment	body		object					false	"Deployment (comments)"
+//	@Success		201			{object}	map[string]interface{}	"Deployment"
+//	@Security		BearerAuth
+//	@Router			/admin/config/deployments [post]
+func HandleCreateSysconfigDeploymentAPI(c *gin.Context) {
+	var req sysconfigDeployRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid request: " + err.Error()})
+			return
+		}
+	}
+	svc := sysconfigService(c)
+	if svc == nil {
+		return
+	}
+	deployment, err := svc.Deploy(c.Request.Context(), req.Comments, GetUserIDFromCtx(c, 1))
+	if err != nil {
+		sysconfigError(c, err, "deploy settings")
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"success": true, "data": deployment})
+}
+
+// HandleGetSysconfigDeploymentAPI handles GET /api/v1/admin/config/deployments/:id.
+//
+//	@Summary		Get a configuration deployment
+//	@Description	Returns a deployment with the setting values it recorded. Password values are masked.
+//	@Tags			System Configuration
+//	@Produce		json
+//	@Param			id	path		int						true	"Deployment ID"
+//	@Success		200	{object}	map[string]interface{}	"Deployment"
+//	@Failure		404	{object}	map[string]interface{}	"Deployment not found"
+//	@Security		BearerAuth
+//	@Router			/admin/config/deployments/{id} [get]
+func HandleGetSysconfigDeploymentAPI(c *gin.Context) {
+	id, ok := sysconfigDeploymentID(c)
+	if !ok {
+		return
+	}
+	svc := sysconfigService(c)
+	if svc == nil {
+		return
+	}
+	deployment, err := svc.Deployment(c.Request.Context(), id)
+	if err != nil {
+		sysconfigError(c, err, "load deployment")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": deployment})
+}
+
+// HandleRestoreSysconfigDeploymentAPI handles POST /api/v1/admin/config/deployments/:id/restore.
+//
+//	@Summary		Restore a configuration deployment
+//	@Description	Sets the settings back to the values recorded by a deployment. Read-only settings and settings the deployment does not know are left alone. Deploy again to record the result.
+//	@Tags			System Configuration
+//	@Produce		json
+//	@Param			id	path		int						true	"Deployment ID"
+//	@Success		200	{object}	map[string]interface{}	"Changed settings"
+//	@Failure		400	{object}	map[string]interface{}	"A recorded value no longer passes validation"
+//	@Failure		404	{object}	map[string]interface{}	"Deployment not found"
+//	@Failure		409	{object}	map[string]interface{}	"Deployment was not made by GoatFlow"
+//	@Security		BearerAuth
+//	@Router			/admin/config/deployments/{id}/restore [post]
+func HandleRestoreSysconfigDeploymentAPI(c *gin.Context) {
+	id, ok := sysconfigDeploymentID(c)
+	if !ok {
+		return
+	}
+	svc := sysconfigService(c)
+	if svc == nil {
+		return
+	}
+	changed, err := svc.Restore(c.Request.Context(), id, GetUserIDFromCtx(c, 1))
+	if err != nil {
+		sysconfigError(c, err, "restore deployment")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": changed})
+}
+
+// HandleSysconfigDeploymentDiffAPI handles GET /api/v1/admin/config/deployments/:id/diff.
+//
+//	@Summary		Diff a configuration deployment
+//	@Description	Lists the settings a deployment added, removed or changed, compared with the closest earlier deployment (or the deployment given in against). The first deployment is compared with the defaults. Password values are masked.
+//	@Tags			System Configuration
+//	@Produce		json
+//	@Param			id		path		int						true	"Deployment ID"
+//	@Param			against	query		int						false	"Deployment to compare with"
+//	@Success		200		{object}	map[string]interface{}	"Per-setting changes"
+//	@Failure		404		{object}	map[string]interface{}	"Deployment not found"
+//	@Failure		409		{object}	map[string]interface{}	"Deployment was not made by GoatFlow"
+//	@Security		BearerAuth
+//	@Router			/admin/config/deployments/{id}/diff [get]
+func HandleSysconfigDeploymentDiffAPI(c *gin.Context) {
+	id, ok := sysconfigDeploymentID(c)
+	if !ok {
+		return
+	}
+	against := 0
+	if raw := c.Query("against"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid deployment ID in against"})
+			return
+		}
+		against = n
+	}
+	svc := sysconfigService(c)
+	if svc == nil {
+		return
+	}
+	diff, err := svc.DeploymentDiff(c.Request.Context(), id, against)
+	if err != nil {
+		sysconfigError(c, err, "diff deployment")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": diff})
+}
+
+// HandleRollbackSysconfigDeploymentAPI handles POST /api/v1/admin/config/deployments/:id/rollback.
+//
+//	@Summary		Roll back to a configuration deployment
+//	@Description	Sets the settings back to the values recorded by a deployment and records the result as a new deployment, in one transaction. Read-only settings and settings the deployment does not know are left alone.
+//	@Tags			System Configuration
+//	@Accept			json
+//	@Produce		json
+//	@Param			id			path		int						true	"Deployment ID"
+//	@Param			rollback	body		object					false	"Rollback (comments)"
+//	@Success		201			{object}	map[string]interface{}	"New deployment and changed settings"
+//	@Failure		400			{object}	map[string]interface{}	"A recorded value no longer passes validation"
+//	@Failure		404			{object}	map[string]interface{}	"Deployment not found"
+//	@Failure		409			{object}	map[string]interface{}	"Deployment was not made by GoatFlow"
+//	@Security		BearerAuth
+//	@Router			/admin/config/deployments/{id}/rollback [post]
+func HandleRollbackSysconfigDeploymentAPI(c *gin.Context) {
+	id, ok := sysconfigDeploymentID(c)
+	if !ok {
+		return
+	}
+	var req sysconfigDeployRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid request: " + err.Error()})
+			return
+		}
+	}
+	svc := sysconfigService(c)
+	if svc == nil {
+		return
+	}
+	result, err := svc.Rollback(c.Request.Context(), id, req.Comments, GetUserIDFromCtx(c, 1))
+	if err != nil {
+		sysconfigError(c, err, "roll back deployment")
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"success": true, "data": result})
+}
@@ -0,0 +1,89 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/service"
+)
+
+func TestSysconfigAPI_InvalidRequests(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.PUT("/api/v1/admin/config", HandleUpdateSysconfigAPI)
+	router.POST("/api/v1/admin/config/deployments/:id/restore", HandleRestoreSysconfigDeploymentAPI)
+	router.GET("/api/v1/admin/config/deployments/:id/diff", HandleSysconfigDeploymentDiffAPI)
+	router.POST("/api/v1/admin/config/deployments/:id/rollback", HandleRollbackSysconfigDeploymentAPI)
+
+	for _, tc := range []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		want   string
+	}{
+		{"not JSON", http.MethodPut, "/api/v1/admin/config", `SessionMaxTime=3600`, "Invalid request"},
+		{"empty", http.MethodPut, "/api/v1/admin/config", `{"settings": {}}`, "settings or reset is required"},
+		{"object value", http.MethodPut, "/api/v1/admin/config", `{"settings": {"SessionMaxTime": {"v": 1}}}`, "unsupported value"},
+		{"bad deployment id", http.MethodPost, "/api/v1/admin/config/deployments/abc/restore", ``, "Invalid deployment ID"},
+		{"bad against", http.MethodGet, "/api/v1/admin/config/deployments/3/diff?against=x", ``, "Invalid deployment ID in against"},
+		{"rollback not JSON", http.MethodPost, "/api/v1/admin/config/deployments/3/rollback", `comments=x`, "Invalid request"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Contains(t, w.Body.String(), tc.want)
+		})
+	}
+}
+
+func TestSysconfigRequestValue(t *testing.T) {
+	for _, tc := range []struct {
+		in   interface{}
+		want string
+	}{
+		{"Ticket#", "Ticket#"},
+		{true, "true"},
+		{float64(3600), "3600"},
+		{[]interface{}{"en", "de"}, `["en","de"]`},
+		{nil, ""},
+	} {
+		got, err := sysconfigRequestValue(tc.in)
+		require.NoError(t, err)
+		assert.Equal(t, tc.want, got)
+	}
+	_, err := sysconfigRequestValue([]interface{}{"en", 1})
+	assert.Error(t, err)
+}
+
+func TestSysconfigError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	for _, tc := range []struct {
+		err  error
+		code int
+	}{
+		{&service.SysconfigValidationError{Errors: map[string]string{"SessionMaxTime": "must be at least 300"}}, http.StatusBadRequest},
+		{service.ErrSysconfigNotFound, http.StatusNotFound},
+		{service.ErrSysconfigDeploymentNotFound, http.StatusNotFound},
+		{service.ErrSysconfigDeploymentFormat, http.StatusConflict},
+		{fmt.Errorf("connection reset"), http.StatusInternalServerError},
+	} {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		sysconfigError(c, tc.err, "update settings")
+		assert.Equal(t, tc.code, w.Code, tc.err.Error())
+	}
+}
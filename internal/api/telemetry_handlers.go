@@ -0,0 +1,191 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/flosch/pongo2/v6"
+	"github.com/gin-gonic/gin"
+
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/service"
+)
+
+// telemetryService returns the service, writing 503 when the database is
+// unavailable.
+func telemetryService(c *gin.Context) *service.TelemetryService {
+	db, err := database.GetDB()
+	if err != nil || db == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"success": false, "error": "Database unavailable"})
+		return nil
+	}
+	return service.NewTelemetryService(db)
+}
+
+// telemetryError maps TelemetryService errors to responses.
+func telemetryError(c *gin.Context, err error, action string) {
+	switch {
+	case errors.Is(err, service.ErrTelemetryInvalid), errors.Is(err, service.ErrTelemetryDays):
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+	case errors.Is(err, service.ErrTelemetrySubmitDisabled):
+		c.JSON(http.StatusConflict, gin.H{"success": false, "error": err.Error()})
+	default:
+		log.Printf("telemetry api: %s failed: %v", action, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to " + action})
+	}
+}
+
+// handleAdminSystemInsights renders the System Insights page.
+func handleAdminSystemInsights(c *gin.Context) {
+	if htmxHandlerSkipDB() || getPongo2Renderer() == nil || getPongo2Renderer().TemplateSet() == nil {
+		c.Data(http.StatusOK, "text/html; charset=utf-8", []byte("<main>System Insights</main>"))
+		return
+	}
+	getPongo2Renderer().HTML(c, http.StatusOK, "pages/admin/system_insights.pongo2", pongo2.Context{
+		"ActivePage": "admin",
+		"User":       getUserMapForTemplate(c),
+	})
+}
+
+// HandleGetTelemetrySettingsAPI handles GET /api/v1/admin/telemetry.
+//
+//	@Summary		Get telemetry settings
+//	@Description	Returns whether anonymous usage is counted and submitted, the endpoint and the outcome of the last submission.
+//	@Tags			Telemetry
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}	"Telemetry settings"
+//	@Security		BearerAuth
+//	@Router			/admin/telemetry [get]
+func HandleGetTelemetrySettingsAPI(c *gin.Context) {
+	svc := telemetryService(c)
+	if svc == nil {
+		return
+	}
+	settings, err := svc.Settings(c.Request.Context())
+	if err != nil {
+		telemetryError(c, err, "load telemetry settings")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": settings})
+}
+
+// HandleUpdateTelemetrySettingsAPI handles PUT /api/v1/admin/telemetry.
+//
+//	@Summary		Update telemetry settings
+//	@Description	Opts in to or out of counting anonymous usage and submitting a daily report to an endpoint. Submitting requires counting and an http or https endpoint.
+//	@Tags			Telemetry
+//	@Accept			json
+//	@Produce		json
+//	@Param			settings	body		object	true	"enabled, submit, endpoint"
+//	@Success		200			{object}	map[string]interface{}	"Telemetry settings"
+//	@Failure		400			{object}	map[string]interface{}	"Invalid settings"
+//	@Security		BearerAuth
+//	@Router			/admin/telemetry [put]
+func HandleUpdateTelemetrySettingsAPI(c *gin.Context) {
+	var req struct {
+		Enabled  bool   `json:"enabled"`
+		Submit   bool   `json:"submit"`
+		Endpoint string `json:"endpoint"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid request body"})
+		return
+	}
+	svc := telemetryService(c)
+	if svc == nil {
+		return
+	}
+	settings, err := svc.UpdateSettings(c.Request.Context(), req.Enabled, req.Submit, req.Endpoint, GetUserIDFromCtx(c, 1))
+	if err != nil {
+		telemetryError(c, err, "save telemetry settings")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": settings})
+}
+
+// HandleTelemetryInsightsAPI handles GET /api/v1/admin/telemetry/insights.
+//
+//	@Summary		Get system insights
+//	@Description	Summarises the anonymous usage counted over the last days, today included: requests and server errors per route, plugin calls, failures and runtimes, and daily totals.
+//	@Tags			Telemetry
+//	@Produce		json
+//	@Param			days	query		int	false	"Number of days, 1 to 365 (default 30)"
+//	@Success		200		{object}	map[string]interface{}	"System insights"
+//	@Failure		400		{object}	map[string]interface{}	"Invalid days"
+//	@Security		BearerAuth
+//	@Router			/admin/telemetry/insights [get]
+func HandleTelemetryInsightsAPI(c *gin.Context) {
+	days := 0
+	if raw := c.Query("days"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": service.ErrTelemetryDays.Error()})
+			return
+		}
+		days = n
+	}
+	svc := telemetryService(c)
+	if svc == nil {
+		return
+	}
+	insights, err := svc.Insights(c.Request.Context(), days)
+	if err != nil {
+		telemetryError(c, err, "load system insights")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": insights})
+}
+
+// HandleTelemetryReportAPI handles GET /api/v1/admin/telemetry/report.
+//
+//	@Summary		Preview telemetry report
+//	@Description	Returns yesterday's anonymous report exactly as it would be submitted.
+//	@Tags			Telemetry
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}	"Telemetry report"
+//	@Security		BearerAuth
+//	@Router			/admin/telemetry/report [get]
+func HandleTelemetryReportAPI(c *gin.Context) {
+	svc := telemetryService(c)
+	if svc == nil {
+		return
+	}
+	report, err := svc.Report(c.Request.Context(), time.Now().UTC().AddDate(0, 0, -1))
+	if err != nil {
+		telemetryError(c, err, "build telemetry report")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": report})
+}
+
+// HandleSubmitTelemetryAPI handles POST /api/v1/admin/telemetry/submit.
+//
+//	@Summary		Submit telemetry report
+//	@Description	Sends yesterday's anonymous report to the configured endpoint now, instead of waiting for the nightly telemetry job.
+//	@Tags			Telemetry
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}	"Submitted report"
+//	@Failure		409	{object}	map[string]interface{}	"Submission not enabled"
+//	@Failure		502	{object}	map[string]interface{}	"Endpoint failed"
+//	@Security		BearerAuth
+//	@Router			/admin/telemetry/submit [post]
+func HandleSubmitTelemetryAPI(c *gin.Context) {
+	svc := telemetryService(c)
+	if svc == nil {
+		return
+	}
+	report, err := svc.Submit(c.Request.Context())
+	if errors.Is(err, service.ErrTelemetrySubmitDisabled) {
+		telemetryError(c, err, "submit telemetry")
+		return
+	}
+	if err != nil {
+		log.Printf("telemetry api: submit failed: %v", err)
+		c.JSON(http.StatusBadGateway, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": report})
+}
@@ -0,0 +1,56 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/telemetry"
+	"github.com/goatkit/goatflow/internal/testutil"
+)
+
+func TestTelemetryHandlers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	testutil.UseMigratedDB(t)
+	t.Cleanup(func() { telemetry.SetEnabled(false) })
+
+	router := gin.New()
+	router.GET("/api/v1/admin/telemetry", HandleGetTelemetrySettingsAPI)
+	router.PUT("/api/v1/admin/telemetry", HandleUpdateTelemetrySettingsAPI)
+	router.GET("/api/v1/admin/telemetry/insights", HandleTelemetryInsightsAPI)
+	router.POST("/api/v1/admin/telemetry/submit", HandleSubmitTelemetryAPI)
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do(http.MethodGet, "/api/v1/admin/telemetry", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"enabled":false`)
+
+	w = do(http.MethodPut, "/api/v1/admin/telemetry", `{"enabled": true, "submit": true}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code, "submitting requires an endpoint")
+	w = do(http.MethodPut, "/api/v1/admin/telemetry", `{"enabled": "yes"}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = do(http.MethodPost, "/api/v1/admin/telemetry/submit", "")
+	assert.Equal(t, http.StatusConflict, w.Code)
+
+	w = do(http.MethodPut, "/api/v1/admin/telemetry", `{"enabled": true}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.True(t, telemetry.Enabled())
+
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodGet, "/api/v1/admin/telemetry/insights?days=week", "").Code)
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodGet, "/api/v1/admin/telemetry/insights?days=0", "").Code)
+	telemetry.RecordRoute("GET", "/api/v1/tickets", http.StatusOK, 0)
+	w = do(http.MethodGet, "/api/v1/admin/telemetry/insights?days=7", "")
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"name":"GET /api/v1/tickets"`)
+}
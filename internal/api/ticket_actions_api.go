@@ -11,6 +11,8 @@ import (
 	"github.com/gin-gonic/gin"
 
 	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/service"
 )
 
 // HandleCloseTicketAPI handles ticket closure via API.
@@ -118,6 +120,13 @@ func HandleCloseTicketAPI(c *gin.Context) {
 		newStateID = 3 // closed unsuccessful
 	}
 
+	if rejectTicketTypeTransition(c, db, ticketID, newStateID) {
+		return
+	}
+	if requestTicketApproval(c, db, models.ApprovalActionClose, ticketID, newStateID, userID, closeRequest.Comment) {
+		return
+	}
+
 	// Start transaction
 	tx, err := db.Begin()
 	if err != nil {
@@ -517,6 +526,19 @@ func HandleAssignTicketAPI(c *gin.Context) {
 		return
 	}
 
+	// Assign to the substitute while the agent is out of office
+	requestedID := assignRequest.AssignedTo
+	assignedTo, ok := resolveAssignee(c, service.NewOutOfOfficeService(db), requestedID)
+	if !ok {
+		return
+	}
+	if assignedTo != requestedID {
+		assignRequest.AssignedTo = assignedTo
+		_ = db.QueryRow(database.ConvertPlaceholders( //nolint:errcheck // Keeps the requested login on failure
+			"SELECT login FROM users WHERE id = ?",
+		), assignedTo).Scan(&assigneeLogin)
+	}
+
 	// Start transaction
 	tx, err := db.Begin()
 	if err != nil {
@@ -619,12 +641,16 @@ func HandleAssignTicketAPI(c *gin.Context) {
 	}
 
 	// Return success response
-	c.JSON(http.StatusOK, gin.H{
+	resp := gin.H{
 		"success":     true,
 		"id":          ticketID,
 		"assigned_to": assignRequest.AssignedTo,
 		"assignee":    assigneeLogin,
 		"comment":     assignRequest.Comment,
 		"assigned_at": time.Now().UTC(),
-	})
+	}
+	if assignRequest.AssignedTo != requestedID {
+		resp["substitute_for"] = requestedID
+	}
+	c.JSON(http.StatusOK, resp)
 }
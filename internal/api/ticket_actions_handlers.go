@@ -19,6 +19,7 @@ import (
 	"github.com/goatkit/goatflow/internal/models"
 	"github.com/goatkit/goatflow/internal/repository"
 	"github.com/goatkit/goatflow/internal/routing"
+	"github.com/goatkit/goatflow/internal/service"
 )
 
 func init() {
@@ -84,6 +85,15 @@ func handleAssignTicket(c *gin.Context) {
 		}
 	}
 
+	// Assign to the substitute while the agent is out of office
+	requestedID := agentID
+	if db != nil {
+		var ok bool
+		if agentID, ok = resolveAssignee(c, service.NewOutOfOfficeService(db), agentID); !ok {
+			return
+		}
+	}
+
 	// If DB unavailable in tests, bypass DB write and return success
 	var updateErr error
 	if db != nil {
@@ -133,12 +143,16 @@ func handleAssignTicket(c *gin.Context) {
 
 	// HTMX trigger header expected by tests (include showMessage and success)
 	c.Header("HX-Trigger", `{"showMessage":{"type":"success","text":"Assigned"},"success":true}`)
-	c.JSON(http.StatusOK, gin.H{
+	resp := gin.H{
 		"message":   fmt.Sprintf("Ticket %s assigned to %s", ticketID, agentName),
 		"agent_id":  agentID,
 		"ticket_id": ticketID,
 		"time":      time.Now().Format("2006-01-02 15:04"),
-	})
+	}
+	if agentID != requestedID {
+		resp["substitute_for"] = requestedID
+	}
+	c.JSON(http.StatusOK, resp)
 }
 
 // handleCloseTicket closes a ticket.
@@ -198,6 +212,13 @@ func handleCloseTicket(c *gin.Context) {
 		}
 	}
 
+	if rejectTicketTypeTransition(c, db, ticketIDInt, closeData.StateID) {
+		return
+	}
+	if requestTicketApproval(c, db, models.ApprovalActionClose, ticketIDInt, closeData.StateID, userID, closeData.Notes) {
+		return
+	}
+
 	// Start transaction
 	tx, err := db.Begin()
 	if err != nil {
@@ -829,6 +850,13 @@ func handleUpdateTicketStatus(c *gin.Context) {
 		userID = 1
 	}
 
+	if rejectTicketTypeTransition(c, db, tid, resolvedStateID) {
+		return
+	}
+	if requestTicketApproval(c, db, models.ApprovalActionClose, tid, resolvedStateID, int(userID), "") {
+		return
+	}
+
 	var previousTicket *models.Ticket
 	if prev, perr := repo.GetByID(uint(tid)); perr == nil {
 		previousTicket = prev
@@ -958,6 +986,19 @@ func handleGetAvailableAgents(c *gin.Context) {
 		log.Printf("error iterating agents: %v", err)
 	}
 
+	// Flag absent agents so the picker can show who gets the ticket instead
+	ooo := service.NewOutOfOfficeService(db)
+	for _, agent := range agents {
+		status, err := ooo.Get(c.Request.Context(), agent["id"].(int))
+		if err != nil || !status.Active {
+			continue
+		}
+		agent["out_of_office"] = true
+		if status.SubstituteID > 0 {
+			agent["substitute_id"] = status.SubstituteID
+		}
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"agents":  agents,
@@ -0,0 +1,398 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/repository"
+	"github.com/goatkit/goatflow/internal/service"
+)
+
+// archivePolicyRequest is the JSON body accepted by the archive policy
+// create/update handlers.
+type archivePolicyRequest struct {
+	Name             string   `json:"name" binding:"required"`
+	StateTypes       []string `json:"state_types"`
+	QueueID          *int     `json:"queue_id"`
+	ArchiveAfterDays int      `json:"archive_after_days" binding:"required"`
+	MoveArticles     *bool    `json:"move_articles"`
+	PurgeAfterDays   int      `json:"purge_after_days"`
+	ValidID          int      `json:"valid_id"`
+}
+
+func (r *archivePolicyRequest) policy() *models.ArchivePolicy {
+	p := &models.ArchivePolicy{
+		Name:             r.Name,
+		StateTypes:       r.StateTypes,
+		QueueID:          r.QueueID,
+		ArchiveAfterDays: r.ArchiveAfterDays,
+		PurgeAfterDays:   r.PurgeAfterDays,
+		ValidID:          r.ValidID,
+	}
+	if r.MoveArticles != nil {
+		p.MoveArticles = *r.MoveArticles
+	}
+	return p
+}
+
+// archiveRunRequest is the JSON body of HandleStartArchiveRunAPI.
+type archiveRunRequest struct {
+	PolicyID *int `json:"policy_id"`
+	DryRun   bool `json:"dry_run"`
+}
+
+// ticketArchiveService returns the service, writing 503 when the database
+// is unavailable.
+func ticketArchiveService(c *gin.Context) *service.TicketArchiveService {
+	db, err := database.GetDB()
+	if err != nil || db == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"success": false, "error": "Database unavailable"})
+		return nil
+	}
+	svc := service.NewTicketArchiveService(db)
+	svc.SetIndexer(TicketSearchIndexer())
+	return svc
+}
+
+// ticketArchiveError maps TicketArchiveService errors to responses.
+func ticketArchiveError(c *gin.Context, err error, action string) {
+	switch {
+	case errors.Is(err, service.ErrArchivePolicyNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Archive policy not found"})
+	case errors.Is(err, service.ErrArchiveTicketNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Ticket not found"})
+	case errors.Is(err, repository.ErrTicketAlreadyArchived),
+		errors.Is(err, repository.ErrTicketNotArchived):
+		c.JSON(http.StatusConflict, gin.H{"success": false, "error": err.Error()})
+	case errors.Is(err, service.ErrArchivePolicyName),
+		errors.Is(err, service.ErrArchivePolicyDays),
+		errors.Is(err, service.ErrArchivePolicyPurge):
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+	default:
+		log.Printf("archive api: %s failed: %v", action, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to " + action})
+	}
+}
+
+// archivePathID parses the :id path parameter, writing 400 when it is
+// invalid. what names the kind of ID in the error.
+func archivePathID(c *gin.Context, what string) (int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid " + what + " ID"})
+		return 0, false
+	}
+	return id, true
+}
+
+// HandleListArchivePoliciesAPI handles GET /api/v1/admin/archive/policies.
+//
+//	@Summary		List archive policies
+//	@Tags			Ticket Archive
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}	"Archive policies"
+//	@Security		BearerAuth
+//	@Router			/admin/archive/policies [get]
+func HandleListArchivePoliciesAPI(c *gin.Context) {
+	svc := ticketArchiveService(c)
+	if svc == nil {
+		return
+	}
+	policies, err := svc.ListPolicies(c.Request.Context())
+	if err != nil {
+		ticketArchiveError(c, err, "load archive policies")
+		return
+	}
+	if policies == nil {
+		policies = []*models.ArchivePolicy{}
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": policies})
+}
+
+// HandleCreateArchivePolicyAPI handles POST /api/v1/admin/archive/policies.
+//
+//	@Summary		Create archive policy
+//	@Tags			Ticket Archive
+//	@Accept			json
+//	@Produce		json
+//	@Param			policy	body		object	true	"Policy (name, state_types, queue_id, archive_after_days, move_articles, purge_after_days, valid_id)"
+//	@Success		201		{object}	map[string]interface{}	"Archive policy created"
+//	@Failure		400		{object}	map[string]interface{}	"Invalid request"
+//	@Security		BearerAuth
+//	@Router			/admin/archive/policies [post]
+func HandleCreateArchivePolicyAPI(c *gin.Context) {
+	var req archivePolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid archive policy: " + err.Error()})
+		return
+	}
+	svc := ticketArchiveService(c)
+	if svc == nil {
+		return
+	}
+	p, err := svc.CreatePolicy(c.Request.Context(), req.policy(), GetUserIDFromCtx(c, 1))
+	if err != nil {
+		ticketArchiveError(c, err, "create archive policy")
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"success": true, "data": p})
+}
+
+// HandleUpdateArchivePolicyAPI handles PUT /api/v1/admin/archive/policies/:id.
+//
+//	@Summary		Update archive policy
+//	@Tags			Ticket Archive
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int		true	"Archive policy ID"
+//	@Param			policy	body		object	true	"Policy"
+//	@Success		200		{object}	map[string]interface{}	"Archive policy updated"
+//	@Failure		400		{object}	map[string]interface{}	"Invalid request"
+//	@Failure		404		{object}	map[string]interface{}	"Archive policy not found"
+//	@Security		BearerAuth
+//	@Router			/admin/archive/policies/{id} [put]
+func HandleUpdateArchivePolicyAPI(c *gin.Context) {
+	id, ok := archivePathID(c, "archive policy")
+	if !ok {
+		return
+	}
+	var req archivePolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid archive policy: " + err.Error()})
+		return
+	}
+	svc := ticketArchiveService(c)
+	if svc == nil {
+		return
+	}
+	p := req.policy()
+	p.ID = id
+	updated, err := svc.UpdatePolicy(c.Request.Context(), p, GetUserIDFromCtx(c, 1))
+	if err != nil {
+		ticketArchiveError(c, err, "update archive policy")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": updated})
+}
+
+// HandleDeleteArchivePolicyAPI handles DELETE /api/v1/admin/archive/policies/:id.
+//
+//	@Summary		Delete archive policy
+//	@Description	Tickets archived by the policy stay archived.
+//	@Tags			Ticket Archive
+//	@Produce		json
+//	@Param			id	path		int	true	"Archive policy ID"
+//	@Success		200	{object}	map[string]interface{}	"Archive policy deleted"
+//	@Failure		404	{object}	map[string]interface{}	"Archive policy not found"
+//	@Security		BearerAuth
+//	@Router			/admin/archive/policies/{id} [delete]
+func HandleDeleteArchivePolicyAPI(c *gin.Context) {
+	id, ok := archivePathID(c, "archive policy")
+	if !ok {
+		return
+	}
+	svc := ticketArchiveService(c)
+	if svc == nil {
+		return
+	}
+	if err := svc.DeletePolicy(c.Request.Context(), id); err != nil {
+		ticketArchiveError(c, err, "delete archive policy")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// HandleListArchiveRunsAPI handles GET /api/v1/admin/archive/runs.
+//
+//	@Summary		List archive runs
+//	@Tags			Ticket Archive
+//	@Produce		json
+//	@Param			limit	query		int	false	"Maximum runs (default 50)"
+//	@Success		200		{object}	map[string]interface{}	"Archive runs, newest first"
+//	@Security		BearerAuth
+//	@Router			/admin/archive/runs [get]
+func HandleListArchiveRunsAPI(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if limit <= 0 || limit > 500 {
+		limit = 50
+	}
+	svc := ticketArchiveService(c)
+	if svc == nil {
+		return
+	}
+	runs, err := svc.ListRuns(c.Request.Context(), limit)
+	if err != nil {
+		ticketArchiveError(c, err, "load archive runs")
+		return
+	}
+	if runs == nil {
+		runs = []*models.ArchiveRun{}
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": runs})
+}
+
+// HandleStartArchiveRunAPI handles POST /api/v1/admin/archive/runs.
+//
+//	@Summary		Run archive policies
+//	@Description	Applies every valid policy, or only policy_id, once. dry_run counts the tickets without changing them.
+//	@Tags			Ticket Archive
+//	@Accept			json
+//	@Produce		json
+//	@Param			run	body		object	false	"Run (policy_id, dry_run)"
+//	@Success		200	{object}	map[string]interface{}	"Finished run"
+//	@Failure		404	{object}	map[string]interface{}	"Archive policy not found"
+//	@Security		BearerAuth
+//	@Router			/admin/archive/runs [post]
+func HandleStartArchiveRunAPI(c *gin.Context) {
+	var req archiveRunRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid archive run: " + err.Error()})
+			return
+		}
+	}
+	svc := ticketArchiveService(c)
+	if svc == nil {
+		return
+	}
+	run, err := svc.Run(c.Request.Context(), req.PolicyID, req.DryRun, GetUserIDFromCtx(c, 1))
+	if err != nil && run == nil {
+		ticketArchiveError(c, err, "run archive policies")
+		return
+	}
+	if err != nil {
+		log.Printf("archive api: run %d failed: %v", run.ID, err)
+	}
+	c.JSON(http.StatusOK, gin.H{"success": err == nil, "data": run})
+}
+
+// HandleListArchivedTicketsAPI handles GET /api/v1/admin/archive/tickets.
+//
+//	@Summary		List archived tickets
+//	@Tags			Ticket Archive
+//	@Produce		json
+//	@Param			limit	query		int	false	"Maximum tickets (default 50)"
+//	@Param			offset	query		int	false	"Offset"
+//	@Success		200		{object}	map[string]interface{}	"Archived tickets, most recent first"
+//	@Security		BearerAuth
+//	@Router			/admin/archive/tickets [get]
+func HandleListArchivedTicketsAPI(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if limit <= 0 || limit > 500 {
+		limit = 50
+	}
+	offset, _ := strconv.Atoi(c.Query("offset"))
+	if offset < 0 {
+		offset = 0
+	}
+	svc := ticketArchiveService(c)
+	if svc == nil {
+		return
+	}
+	tickets, err := svc.ListArchived(c.Request.Context(), limit, offset)
+	if err != nil {
+		ticketArchiveError(c, err, "load archived tickets")
+		return
+	}
+	if tickets == nil {
+		tickets = []*models.TicketArchive{}
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": tickets})
+}
+
+// HandleArchiveTicketAPI handles POST /api/v1/admin/archive/tickets/:id.
+//
+//	@Summary		Archive ticket
+//	@Description	Archives a ticket now regardless of the policies. With move_articles its article content moves to the archive tables.
+//	@Tags			Ticket Archive
+//	@Produce		json
+//	@Param			id				path		int		true	"Ticket ID"
+//	@Param			move_articles	query		bool	false	"Move article content to the archive tables"
+//	@Success		200	{object}	map[string]interface{}	"Ticket archived"
+//	@Failure		404	{object}	map[string]interface{}	"Ticket not found"
+//	@Failure		409	{object}	map[string]interface{}	"Ticket already archived"
+//	@Security		BearerAuth
+//	@Router			/admin/archive/tickets/{id} [post]
+func HandleArchiveTicketAPI(c *gin.Context) {
+	id, ok := archivePathID(c, "ticket")
+	if !ok {
+		return
+	}
+	svc := ticketArchiveService(c)
+	if svc == nil {
+		return
+	}
+	moveArticles, err := strconv.ParseBool(c.DefaultQuery("move_articles", "false"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid move_articles"})
+		return
+	}
+	moved, err := svc.Archive(c.Request.Context(), id, moveArticles, GetUserIDFromCtx(c, 1))
+	if err != nil {
+		ticketArchiveError(c, err, "archive ticket")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{"ticket_id": id, "articles_moved": moved}})
+}
+
+// HandleRestoreArchivedTicketAPI handles POST /api/v1/admin/archive/tickets/:id/restore.
+//
+//	@Summary		Restore archived ticket
+//	@Tags			Ticket Archive
+//	@Produce		json
+//	@Param			id	path		int	true	"Ticket ID"
+//	@Success		200	{object}	map[string]interface{}	"Ticket restored"
+//	@Failure		404	{object}	map[string]interface{}	"Ticket not found"
+//	@Failure		409	{object}	map[string]interface{}	"Ticket not archived"
+//	@Security		BearerAuth
+//	@Router			/admin/archive/tickets/{id}/restore [post]
+func HandleRestoreArchivedTicketAPI(c *gin.Context) {
+	id, ok := archivePathID(c, "ticket")
+	if !ok {
+		return
+	}
+	svc := ticketArchiveService(c)
+	if svc == nil {
+		return
+	}
+	moved, err := svc.Restore(c.Request.Context(), id, GetUserIDFromCtx(c, 1))
+	if err != nil {
+		ticketArchiveError(c, err, "restore ticket")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{"ticket_id": id, "articles_moved": moved}})
+}
+
+// HandlePurgeArchivedTicketAPI handles DELETE /api/v1/admin/archive/tickets/:id.
+//
+//	@Summary		Purge archived ticket
+//	@Description	Permanently deletes an archived ticket with its articles and history. This cannot be undone.
+//	@Tags			Ticket Archive
+//	@Produce		json
+//	@Param			id	path		int	true	"Ticket ID"
+//	@Success		200	{object}	map[string]interface{}	"Ticket purged"
+//	@Failure		404	{object}	map[string]interface{}	"Ticket not found"
+//	@Failure		409	{object}	map[string]interface{}	"Ticket not archived"
+//	@Security		BearerAuth
+//	@Router			/admin/archive/tickets/{id} [delete]
+func HandlePurgeArchivedTicketAPI(c *gin.Context) {
+	id, ok := archivePathID(c, "ticket")
+	if !ok {
+		return
+	}
+	svc := ticketArchiveService(c)
+	if svc == nil {
+		return
+	}
+	if err := svc.Purge(c.Request.Context(), id); err != nil {
+		ticketArchiveError(c, err, "purge ticket")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
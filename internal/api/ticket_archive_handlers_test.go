@@ -0,0 +1,51 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTicketArchiveAPI_InvalidRequests(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.POST("/api/v1/admin/archive/policies", HandleCreateArchivePolicyAPI)
+	router.PUT("/api/v1/admin/archive/policies/:id", HandleUpdateArchivePolicyAPI)
+	router.DELETE("/api/v1/admin/archive/policies/:id", HandleDeleteArchivePolicyAPI)
+	router.POST("/api/v1/admin/archive/runs", HandleStartArchiveRunAPI)
+	router.POST("/api/v1/admin/archive/tickets/:id", HandleArchiveTicketAPI)
+	router.POST("/api/v1/admin/archive/tickets/:id/restore", HandleRestoreArchivedTicketAPI)
+	router.DELETE("/api/v1/admin/archive/tickets/:id", HandlePurgeArchivedTicketAPI)
+
+	for _, tc := range []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		want   string
+	}{
+		{"no name", http.MethodPost, "/api/v1/admin/archive/policies", `{"archive_after_days": 90}`, "Invalid archive policy"},
+		{"no days", http.MethodPost, "/api/v1/admin/archive/policies", `{"name": "Closed"}`, "Invalid archive policy"},
+		{"update bad id", http.MethodPut, "/api/v1/admin/archive/policies/abc", `{"name": "x"}`, "Invalid archive policy ID"},
+		{"delete zero id", http.MethodDelete, "/api/v1/admin/archive/policies/0", ``, "Invalid archive policy ID"},
+		{"bad run", http.MethodPost, "/api/v1/admin/archive/runs", `{"dry_run": "yes"}`, "Invalid archive run"},
+		{"archive bad id", http.MethodPost, "/api/v1/admin/archive/tickets/x", ``, "Invalid ticket ID"},
+		{"restore bad id", http.MethodPost, "/api/v1/admin/archive/tickets/-3/restore", ``, "Invalid ticket ID"},
+		{"purge bad id", http.MethodDelete, "/api/v1/admin/archive/tickets/0", ``, "Invalid ticket ID"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Contains(t, w.Body.String(), tc.want)
+		})
+	}
+}
@@ -1,8 +1,8 @@
 package api
 
 import (
-	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"html"
 	"io"
@@ -20,8 +20,9 @@ import (
 	"github.com/goatkit/goatflow/internal/config"
 	"github.com/goatkit/goatflow/internal/database"
 	"github.com/goatkit/goatflow/internal/models"
-	"github.com/goatkit/goatflow/internal/repository"
+	"github.com/goatkit/goatflow/internal/preview"
 	"github.com/goatkit/goatflow/internal/service"
+	"github.com/goatkit/goatflow/internal/storage"
 
 	"log"
 )
@@ -243,14 +244,6 @@ func handleUploadAttachment(c *gin.Context) {
 		return
 	}
 
-	// Check file size
-	if header.Size > MaxFileSize {
-		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
-			"error": fmt.Sprintf("File size exceeds maximum of %dMB", MaxFileSize/(1024*1024)),
-		})
-		return
-	}
-
 	// Check attachment count for ticket
 	if existingAttachments, exists := attachmentsByTicket[ticketID]; exists {
 		if len(existingAttachments) >= MaxAttachments {
@@ -282,11 +275,6 @@ func handleUploadAttachment(c *gin.Context) {
 		return
 	}
 
-	// Initialize storage service (shared)
-	storageService := GetStorageService()
-	// Storage path will be computed after we know the target article (OTRS layout)
-	storagePath := ""
-
 	// Detect and normalize content type
 	// 1) Start with browser-provided header (may include parameters and aliases)
 	contentType := normalizeMimeType(header.Header.Get("Content-Type"))
@@ -301,25 +289,13 @@ func handleUploadAttachment(c *gin.Context) {
 		contentType = normalizeMimeType(contentType)
 	}
 
-	// Enforce config-based limits and allowed types if configured
-	if cfg := config.Get(); cfg != nil {
-		max := cfg.Storage.Attachments.MaxSize
-		if max > 0 && header.Size > max {
-			c.JSON(http.StatusRequestEntityTooLarge, gin.H{
-				"error": fmt.Sprintf("File size exceeds maximum of %dMB", max/(1024*1024)),
-			})
-			return
-		}
-		if len(cfg.Storage.Attachments.AllowedTypes) > 0 && contentType != "" && contentType != "application/octet-stream" {
-			allowed := map[string]struct{}{}
-			for _, t := range cfg.Storage.Attachments.AllowedTypes {
-				allowed[strings.ToLower(t)] = struct{}{}
-			}
-			if _, ok := allowed[strings.ToLower(contentType)]; !ok {
-				c.JSON(http.StatusBadRequest, gin.H{"error": "File type not allowed"})
-				return
-			}
-		}
+	// Enforce the uploader's attachment policy
+	db := attachmentsDB()
+	policy := attachmentPolicy(db, attachmentRole(c))
+	if err := service.NewAttachmentUploadService(db).Check(c.Request.Context(), policy, ticketID, contentType, header.Size); err != nil {
+		status, msg, _ := attachmentPolicyMessage(err)
+		c.JSON(status, gin.H{"error": msg})
+		return
 	}
 
 	// Determine uploader ID from auth context (default to 1)
@@ -332,7 +308,6 @@ func handleUploadAttachment(c *gin.Context) {
 		Filename:    header.Filename,
 		ContentType: contentType,
 		Size:        header.Size,
-		StoragePath: storagePath,
 		Description: c.PostForm("description"),
 		UploadedBy:  uploaderID,
 		UploadedAt:  time.Now(),
@@ -351,85 +326,19 @@ func handleUploadAttachment(c *gin.Context) {
 		attachment.Internal = true
 	}
 
-	// If DB is available and storage is DB, use storage abstraction
-	if db := attachmentsDB(); db != nil {
-		// Find or create an article for this ticket
-		articleRepo := repository.NewArticleRepository(db)
-		latest, latestErr := articleRepo.GetLatestArticleForTicket(uint(ticketID))
-		if latestErr != nil || latest == nil || latest.ID == 0 {
-			art := &models.Article{
-				TicketID:               ticketID,
-				ArticleTypeID:          2,
-				SenderTypeID:           3,
-				CommunicationChannelID: 1,
-				IsVisibleForCustomer:   1,
-				Subject:                "Attachment",
-				Body:                   "",
-				CreateBy:               uploaderID,
-				ChangeBy:               uploaderID,
-			}
-			if err := articleRepo.Create(art); err != nil {
-				// Surface some context for logs
-				fmt.Printf("ERROR: create article for ticket %d failed: %v\n", ticketID, err)
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create article for attachment"})
-				return
-			}
-			latest = art
-		}
-
-		// Use configured storage service; if it's DB, it will insert the attachment row itself.
-		if storageService != nil {
-			ctx := c.Request.Context()
-			// Pass article_id for DB backend
-			ctx = context.WithValue(ctx, service.CtxKeyArticleID, latest.ID)
-			// Pass user_id for audit fields if backend supports it
-			ctx = service.WithUserID(ctx, uploaderID)
-			// Compute OTRS-style storage path now that we know article ID
-			storagePath = service.GenerateOTRSStoragePath(ticketID, latest.ID, header.Filename)
-			// Ensure we start from beginning
-			if _, err := file.Seek(0, 0); err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process file"})
+	// If DB is available, store with the ticket's latest article
+	if db != nil {
+		if _, err := storeTicketAttachment(c.Request.Context(), db, ticketID, 0, uploaderID, file, header, contentType); err != nil {
+			if errors.Is(err, errAttachmentQuarantined) {
+				c.JSON(http.StatusUnprocessableEntity, gin.H{
+					"error":       "Attachment was quarantined by the virus scanner",
+					"scan_status": models.ScanStatusInfected,
+				})
 				return
 			}
-			if _, err := storageService.Store(ctx, file, header, storagePath); err != nil {
-				fmt.Printf("ERROR: storage Store failed for ticket %d article %d: %v\n", ticketID, latest.ID, err)
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store attachment"})
-				return
-			}
-
-			// If storage backend is local (not DB), also create DB metadata row for listing/download
-			if _, isDB := storageService.(*service.DatabaseStorageService); !isDB {
-				// Read content bytes for DB row
-				_, _ = file.Seek(0, 0) //nolint:errcheck // Best effort reset
-				contentBytes, rerr := io.ReadAll(file)
-				if rerr != nil {
-					c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read uploaded file"})
-					return
-				}
-				// Fallback content type
-				ct := contentType
-				if ct == "" {
-					ct = "application/octet-stream"
-				}
-				_, ierr := db.Exec(database.ConvertPlaceholders(`
-					INSERT INTO article_data_mime_attachment (
-						article_id, filename, content_type, content_size, content,
-						disposition, create_time, create_by, change_time, change_by
-					) VALUES (?,?,?,?,?,?,?,?,?,?)`),
-					latest.ID,
-					header.Filename,
-					ct,
-					int64(len(contentBytes)),
-					contentBytes,
-					"attachment",
-					time.Now(), uploaderID, time.Now(), uploaderID,
-				)
-				if ierr != nil {
-					fmt.Printf("ERROR: attachment metadata insert failed for ticket %d article %d: %v\n", ticketID, latest.ID, ierr)
-					c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record attachment"})
-					return
-				}
-			}
+			fmt.Printf("ERROR: storing attachment for ticket %d failed: %v\n", ticketID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store attachment"})
+			return
 		}
 
 		// Let client know something changed for HTMX triggers
@@ -443,7 +352,7 @@ func handleUploadAttachment(c *gin.Context) {
 		attachmentsByTicket[ticketID] = append(attachmentsByTicket[ticketID], nextAttachmentID)
 		nextAttachmentID++
 		// Also attempt to store on filesystem for manual inspection
-		if storageService != nil {
+		if storageService := GetStorageService(); storageService != nil {
 			// Use OTRS layout even in mock (article id unknown => 0)
 			mockPath := service.GenerateOTRSStoragePath(ticketID, 0, header.Filename)
 			_, _ = storageService.Store(c.Request.Context(), file, header, mockPath) //nolint:errcheck // Best effort
@@ -515,7 +424,10 @@ func handleGetAttachments(c *gin.Context) {
 		       COALESCE(att.content_type, 'application/octet-stream'),
 		       COALESCE(att.content_size, 0),
 		       att.create_time, att.create_by,
-		       att.article_id
+		       att.article_id,
+		       (SELECT s.status FROM attachment_scan s
+		        WHERE s.article_id = att.article_id AND s.filename = att.filename
+		        ORDER BY s.id DESC LIMIT 1)
 		FROM article_data_mime_attachment att
 		INNER JOIN article a ON att.article_id = a.id
 		WHERE a.ticket_id = ?
@@ -533,8 +445,9 @@ func handleGetAttachments(c *gin.Context) {
 		var filename, contentType string
 		var contentSize int64
 		var createTime time.Time
+		var scanStatus sql.NullString
 
-		err := rows.Scan(&attID, &filename, &contentType, &contentSize, &createTime, &createBy, &articleID)
+		err := rows.Scan(&attID, &filename, &contentType, &contentSize, &createTime, &createBy, &articleID, &scanStatus)
 		if err != nil {
 			continue
 		}
@@ -551,11 +464,17 @@ func handleGetAttachments(c *gin.Context) {
 			// Keep download URL under /api/tickets/:id/attachments/:attachment_id for consistency
 			"download_url": fmt.Sprintf("/api/tickets/%s/attachments/%d", ticketIDStr, attID),
 		}
+		if scanStatus.Valid {
+			publicAtt["scan_status"] = scanStatus.String
+		}
 
 		// Add thumbnail URL for images
 		if strings.HasPrefix(contentType, "image/") {
 			publicAtt["thumbnail_url"] = fmt.Sprintf("/api/tickets/%s/attachments/%d/thumbnail", ticketIDStr, attID)
 		}
+		if previews := preview.Default(); previews != nil && previews.Supports(contentType) {
+			publicAtt["preview_url"] = fmt.Sprintf("/api/v1/attachments/%d/preview", attID)
+		}
 
 		result = append(result, publicAtt)
 	}
@@ -615,6 +534,7 @@ func handleDownloadAttachment(c *gin.Context) {
 			WHERE att.id = ? AND a.ticket_id = ?
 			LIMIT 1`), attachmentID, ticketID)
 		if scanErr := row.Scan(&filename, &contentType, &contentSize, &contentBytes); scanErr == nil {
+			contentBytes = storage.DecodeBytes(contentBytes)
 			// If DB content is empty (local FS backend), try to fetch from local storage by scanning path
 			if len(contentBytes) == 0 {
 				if buf, ok := findLocalStoredAttachmentBytes(ticketID, filename); ok {
@@ -764,6 +684,7 @@ func handleDeleteAttachment(c *gin.Context) {
 			c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Attachment not found"})
 			return
 		}
+		invalidateAttachmentPreview(c.Request.Context(), attachmentID)
 		c.JSON(http.StatusOK, gin.H{"success": true, "message": "Attachment deleted successfully"})
 		return
 	}
@@ -1133,6 +1054,7 @@ func serveAttachmentInlineRaw(c *gin.Context, ticketIDStr string, ticketID int,
 			JOIN article a ON a.id = att.article_id
 			WHERE att.id = ? AND a.ticket_id = ? LIMIT 1`), attID, ticketID)
 		if err := row.Scan(&filename, &contentType, &content, &articleID); err == nil {
+			content = storage.DecodeBytes(content)
 			if len(content) == 0 {
 				if ss := GetStorageService(); ss != nil {
 					sp := service.GenerateOTRSStoragePath(ticketID, articleID, filename)
@@ -1208,6 +1130,7 @@ func serveAttachmentInlineRaw(c *gin.Context, ticketIDStr string, ticketID int,
 			JOIN article a ON a.id = att.article_id
 			WHERE att.id = ? AND a.ticket_id = ? LIMIT 1`), attID, ticketID)
 		if err := row.Scan(&filename, &contentType, &content, &articleID); err == nil {
+			content = storage.DecodeBytes(content)
 			// Fallback: if DB content is empty (e.g., local FS backend), try retrieving from storage
 			if len(content) == 0 {
 				if ss := GetStorageService(); ss != nil {
@@ -1583,6 +1506,7 @@ func handleGetThumbnail(c *gin.Context) {
 			JOIN article a ON a.id = att.article_id
 			WHERE att.id = ? AND a.ticket_id = ? LIMIT 1`), attID, ticketID)
 		if err := row.Scan(&content, &contentType, &filename, &articleID); err == nil {
+			content = storage.DecodeBytes(content)
 			log.Printf("THUMBNAIL: DB query success - contentType=%s filename=%s articleID=%d contentLen=%d", contentType, filename, articleID, len(content))
 			// If DB content is empty (e.g., local FS backend), try to fetch from storage/local disk
 			if len(content) == 0 {
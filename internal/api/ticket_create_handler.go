@@ -19,6 +19,7 @@ import (
 	"github.com/goatkit/goatflow/internal/constants"
 	"github.com/goatkit/goatflow/internal/database"
 	"github.com/goatkit/goatflow/internal/mailqueue"
+	"github.com/goatkit/goatflow/internal/models"
 	"github.com/goatkit/goatflow/internal/notifications"
 	"github.com/goatkit/goatflow/internal/repository"
 	"github.com/goatkit/goatflow/internal/service"
@@ -29,7 +30,7 @@ import (
 // HandleCreateTicketAPI handles ticket creation via API.
 //
 //	@Summary		Create ticket
-//	@Description	Create a new ticket with optional initial article
+//	@Description	Create a new ticket with optional initial article. When type_id is set, a missing queue, priority or SLA comes from the type's workflow defaults. When impact_id and urgency_id are set and priority_id is not, the priority matrix derives the priority.
 //	@Tags			Tickets
 //	@Accept			json
 //	@Produce		json
@@ -55,6 +56,8 @@ func HandleCreateTicketAPI(c *gin.Context) {
 		PriorityID     int    `json:"priority_id" form:"priority_id"`
 		StateID        int    `json:"state_id" form:"state_id"`
 		TypeID         int    `json:"type_id" form:"type_id"`
+		ImpactID       int    `json:"impact_id" form:"impact_id"`
+		UrgencyID      int    `json:"urgency_id" form:"urgency_id"`
 		Body           string `json:"body" form:"body"`
 		CustomerEmail  string `json:"customer_email" form:"customer_email"`
 		CustomerID     string `json:"customer_id" form:"customer_id"`
@@ -117,6 +120,20 @@ func HandleCreateTicketAPI(c *gin.Context) {
 			}
 		}
 	}
+	if ticketRequest.ImpactID == 0 {
+		if iid := c.PostForm("impact_id"); iid != "" {
+			if parsed, err := strconv.Atoi(iid); err == nil {
+				ticketRequest.ImpactID = parsed
+			}
+		}
+	}
+	if ticketRequest.UrgencyID == 0 {
+		if uid := c.PostForm("urgency_id"); uid != "" {
+			if parsed, err := strconv.Atoi(uid); err == nil {
+				ticketRequest.UrgencyID = parsed
+			}
+		}
+	}
 	if ticketRequest.CustomerID == "" {
 		ticketRequest.CustomerID = strings.TrimSpace(c.PostForm("customer_id"))
 	}
@@ -124,7 +141,8 @@ func HandleCreateTicketAPI(c *gin.Context) {
 		ticketRequest.CustomerUserID = strings.TrimSpace(c.PostForm("customer_user_id"))
 	}
 
-	if ticketRequest.Title == "" || ticketRequest.QueueID == 0 {
+	// The queue may come from the ticket type's workflow defaults.
+	if ticketRequest.Title == "" || (ticketRequest.QueueID == 0 && ticketRequest.TypeID == 0) {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"success": false,
 			"error":   "Invalid ticket request: missing title or queue",
@@ -141,6 +159,21 @@ func HandleCreateTicketAPI(c *gin.Context) {
 		return
 	}
 
+	explicitPriority := ticketRequest.PriorityID > 0
+	if ticketRequest.TypeID > 0 {
+		if err := service.NewTicketTypeService(db).ApplyDefaults(c.Request.Context(), ticketRequest.TypeID,
+			&ticketRequest.QueueID, &ticketRequest.PriorityID, nil); err != nil {
+			log.Printf("create ticket: type %d defaults failed: %v", ticketRequest.TypeID, err)
+		}
+	}
+	if ticketRequest.QueueID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "Invalid ticket request: missing title or queue",
+		})
+		return
+	}
+
 	// Check if user has 'create' permission on the target queue
 	// Customers can create tickets (handled separately), agents need 'create' or 'rw'
 	isCustomer := false
@@ -162,6 +195,17 @@ func HandleCreateTicketAPI(c *gin.Context) {
 		}
 	}
 
+	// The priority matrix derives the priority from the impact and urgency,
+	// overriding the type's default but not a priority the request sets.
+	rating := &models.TicketImpactUrgency{ImpactID: ticketRequest.ImpactID, UrgencyID: ticketRequest.UrgencyID}
+	derivedPriority, ok := deriveNewTicketPriority(c, db, ticketRequest.QueueID, rating)
+	if !ok {
+		return
+	}
+	if derivedPriority > 0 && !explicitPriority {
+		ticketRequest.PriorityID = derivedPriority
+	}
+
 	repo := repository.NewTicketRepository(db)
 	articleRepo := repository.NewArticleRepository(db)
 	svc := service.NewTicketService(repo, service.WithArticleRepository(articleRepo))
@@ -191,6 +235,13 @@ func HandleCreateTicketAPI(c *gin.Context) {
 		return
 	}
 
+	if rating.ImpactID > 0 || rating.UrgencyID > 0 {
+		rating.TicketID = int64(created.ID)
+		if err := service.NewPriorityMatrixService(db).RateTicket(c.Request.Context(), rating, userID); err != nil {
+			log.Printf("create ticket: storing impact and urgency of ticket %d failed: %v", created.ID, err)
+		}
+	}
+
 	// DEBUG: Check for email sending in API handler
 	log.Printf("DEBUG: API ticket created - ID=%d, TN=%s, no email sending in this handler", created.ID, created.TicketNumber)
 
@@ -292,6 +343,8 @@ func HandleCreateTicketAPI(c *gin.Context) {
 			"queue_id":           created.QueueID,
 			"ticket_state_id":    created.TicketStateID,
 			"ticket_priority_id": created.TicketPriorityID,
+			"impact_id":          ticketRatingID(rating.ImpactID),
+			"urgency_id":         ticketRatingID(rating.UrgencyID),
 		},
 	})
 }
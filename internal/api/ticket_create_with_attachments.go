@@ -238,6 +238,7 @@ func handleCreateTicketWithAttachments(c *gin.Context) {
 			log.Printf("WARNING: Failed to process dynamic fields for ticket %d: %v", ticket.ID, dfErr)
 			// Non-fatal - continue with ticket creation
 		}
+		recordTicketTemplateUsage(c)
 	}
 
 	var article *models.Article
@@ -273,10 +274,12 @@ func handleCreateTicketWithAttachments(c *gin.Context) {
 			files = c.Request.MultipartForm.File["file"]
 		}
 		log.Printf("Processing %d attachment(s) for ticket %d", len(files), ticket.ID)
+		policySvc := service.NewAttachmentUploadService(db)
+		policy := attachmentPolicy(db, attachmentRole(c))
 
 		for _, fileHeader := range files {
-			// Validate file size (10MB max)
-			if fileHeader.Size > 10*1024*1024 {
+			// Validate file size against the uploader's attachment policy
+			if policy.MaxFileSize > 0 && fileHeader.Size > policy.MaxFileSize {
 				log.Printf("ERROR: File %s too large (%d bytes)", fileHeader.Filename, fileHeader.Size)
 				c.JSON(http.StatusBadRequest, gin.H{"error": "file too large"})
 				return
@@ -315,23 +318,16 @@ func handleCreateTicketWithAttachments(c *gin.Context) {
 					_, _ = file.Seek(0, 0) //nolint:errcheck // Best effort seek back
 				}
 
-				// Enforce config limits/types if set
-				if cfg := config.Get(); cfg != nil {
-					max := cfg.Storage.Attachments.MaxSize
-					if max > 0 && fileHeader.Size > max {
-						log.Printf("WARNING: %s exceeds max size, skipping", fileHeader.Filename)
-						return
-					}
-					if len(cfg.Storage.Attachments.AllowedTypes) > 0 && contentType != "" && contentType != "application/octet-stream" {
-						allowed := map[string]struct{}{}
-						for _, t := range cfg.Storage.Attachments.AllowedTypes {
-							allowed[strings.ToLower(t)] = struct{}{}
-						}
-						if _, ok := allowed[strings.ToLower(contentType)]; !ok {
-							log.Printf("WARNING: %s type %s not allowed, skipping", fileHeader.Filename, contentType)
-							return
-						}
-					}
+				// Enforce the attachment policy's types and ticket total
+				if err := policySvc.Check(c.Request.Context(), policy, ticket.ID, normalizeMimeType(contentType), fileHeader.Size); err != nil {
+					log.Printf("WARNING: %s not stored: %v", fileHeader.Filename, err)
+					_, msg, _ := attachmentPolicyMessage(err)
+					attachmentInfo = append(attachmentInfo, map[string]interface{}{
+						"filename": fileHeader.Filename,
+						"saved":    false,
+						"error":    msg,
+					})
+					return
 				}
 
 				// Resolve uploader ID
@@ -345,6 +341,22 @@ func handleCreateTicketWithAttachments(c *gin.Context) {
 					ctx = context.WithValue(ctx, service.CtxKeyArticleID, article.ID)
 					ctx = service.WithUserID(ctx, uploaderID)
 
+					if err := scanUploadedFile(ctx, db, file, service.AttachmentScanInput{
+						TicketID:    ticket.ID,
+						ArticleID:   article.ID,
+						Filename:    fileHeader.Filename,
+						ContentType: contentType,
+						UserID:      uploaderID,
+					}); err != nil {
+						log.Printf("WARNING: %s not stored: %v", fileHeader.Filename, err)
+						attachmentInfo = append(attachmentInfo, map[string]interface{}{
+							"filename":    fileHeader.Filename,
+							"saved":       false,
+							"quarantined": true,
+						})
+						return
+					}
+
 					if _, err := storageSvc.Store(ctx, file, fileHeader, storagePath); err != nil {
 						log.Printf("ERROR: storage Store failed for ticket %d article %d: %v", ticket.ID, article.ID, err)
 						return
@@ -92,6 +92,16 @@ func handleTicketDetail(c *gin.Context) {
 		senderTypeColors = make(map[int]string)
 	}
 
+	// Bodies of email replies without quoted messages and signature
+	articleIDs := make([]int64, 0, len(articles))
+	for _, article := range articles {
+		articleIDs = append(articleIDs, int64(article.ID))
+	}
+	visibleTexts, err := repository.NewArticleVisibleTextRepository(db).GetForArticles(c.Request.Context(), articleIDs)
+	if err != nil {
+		log.Printf("Error fetching article visible text: %v", err)
+	}
+
 	// Convert articles to template format - skip the first article (shown separately with description)
 	notes := make([]gin.H, 0, len(articles))
 	firstArticleID := 0
@@ -138,6 +148,9 @@ func handleTicketDetail(c *gin.Context) {
 		if err != nil {
 			log.Printf("Error getting HTML body content for article %d: %v", article.ID, err)
 		}
+		// Email replies are shown without the messages they quote, which
+		// stay available below the visible text
+		var visibleContent string
 		if htmlContent != "" {
 			bodyContent = htmlContent
 		} else if bodyStr, ok := article.Body.(string); ok {
@@ -150,12 +163,21 @@ func handleTicketDetail(c *gin.Context) {
 				// debug removed: rendering HTML article
 				// For HTML content, use it directly (assuming it's from a trusted editor like Tiptap)
 				bodyContent = bodyStr
+				if vt := visibleTexts[int64(article.ID)]; vt != nil {
+					visibleContent = vt.VisibleText
+				}
 			} else if strings.Contains(contentType, "text/markdown") || isMarkdownContent(bodyStr) {
 				// debug removed: rendering markdown article
 				bodyContent = RenderMarkdown(bodyStr)
+				if vt := visibleTexts[int64(article.ID)]; vt != nil {
+					visibleContent = RenderMarkdown(vt.VisibleText)
+				}
 			} else {
 				// debug removed: using plain text article
 				bodyContent = bodyStr
+				if vt := visibleTexts[int64(article.ID)]; vt != nil {
+					visibleContent = vt.VisibleText
+				}
 			}
 		} else {
 			bodyContent = "Content not available"
@@ -213,6 +235,8 @@ func handleTicketDetail(c *gin.Context) {
 			"create_time":             article.CreateTime.Format("2006-01-02 15:04"),
 			"subject":                 article.Subject,
 			"has_html":                hasHTMLContent,
+			"visible_body":            visibleContent,
+			"has_quoted_text":         visibleContent != "",
 			"attachments":             []gin.H{}, // Empty attachments for now
 			"dynamic_fields":          articleDynamicFields,
 		})
@@ -539,6 +563,7 @@ func handleTicketDetail(c *gin.Context) {
 		"first_article_visible_for_customer": firstArticleVisibleForCustomer,
 		"first_article_sender_color":         firstArticleSenderColor,
 		"first_article_sender_type":          firstArticleSenderType,
+		"last_article_id":                    lastArticleID(articles),
 		"age":                                age,
 		"status_id":                          ticket.TicketStateID,
 	}
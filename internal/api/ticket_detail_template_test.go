@@ -177,3 +177,49 @@ func TestTicketDetailTemplatePlainNoteTrimmed(t *testing.T) {
 	require.Contains(t, output, `>Plain text note</div>`)
 	require.NotContains(t, output, `> Plain text note`)
 }
+
+func TestTicketDetailTemplateQuotedTextCollapsed(t *testing.T) {
+	_, filename, _, _ := runtime.Caller(0)
+	baseDir := filepath.Dir(filename)
+
+	loader := pongo2.MustNewLocalFileSystemLoader(filepath.Join(baseDir, "..", "..", "templates"))
+	set := pongo2.NewSet("ticket-detail-test", loader)
+	tmpl, err := set.FromFile("pages/ticket_detail.pongo2")
+	require.NoError(t, err)
+
+	ticket := pongo2.Context{
+		"id":      1,
+		"tn":      "789",
+		"subject": "Test",
+		"notes": []interface{}{
+			pongo2.Context{
+				"id":                      1,
+				"author":                  "Customer",
+				"time":                    "now",
+				"body":                    "Still jammed.\n\nOn Mon, Support wrote:\n> Restart it.",
+				"visible_body":            "Still jammed.",
+				"has_quoted_text":         true,
+				"has_html":                false,
+				"is_visible_for_customer": true,
+			},
+		},
+	}
+
+	ctx := pongo2.Context{
+		"Ticket":               ticket,
+		"PendingStateIDs":      []int{},
+		"TicketStates":         []pongo2.Context{},
+		"RequireNoteTimeUnits": false,
+		"t": func(key string, _ ...interface{}) string {
+			return key
+		},
+	}
+
+	output, err := tmpl.Execute(ctx)
+	require.NoError(t, err)
+
+	require.Contains(t, output, `data-testid="note-visible-content" style="color: var(--gk-text-secondary);">Still jammed.</div>`)
+	require.Contains(t, output, `data-testid="note-show-quoted"`)
+	require.Contains(t, output, `data-testid="note-content" style="color: var(--gk-text-secondary);" hidden>Still jammed.`)
+	require.Contains(t, output, `Restart it.</div>`, "the full message stays in the page")
+}
@@ -0,0 +1,540 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/csv"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xuri/excelize/v2"
+
+	"github.com/goatkit/goatflow/internal/database"
+)
+
+// ticketExportFlushEvery is how many rows are written between flushes to
+// the client, so large exports stream instead of accumulating in buffers.
+const ticketExportFlushEvery = 500
+
+// ticketExportDFPrefix selects a ticket dynamic field as an export column.
+const ticketExportDFPrefix = "DynamicField_"
+
+// ticketExportColumn is one selectable column of a ticket export.
+type ticketExportColumn struct {
+	Key    string
+	Header string
+	Expr   string        // SQL select expression
+	Args   []interface{} // arguments referenced by Expr
+}
+
+// ticketExportStaticColumns lists the built-in export columns in default order.
+var ticketExportStaticColumns = []ticketExportColumn{
+	{Key: "id", Header: "ID", Expr: "t.id"},
+	{Key: "tn", Header: "Ticket#", Expr: "t.tn"},
+	{Key: "title", Header: "Title", Expr: "t.title"},
+	{Key: "queue", Header: "Queue", Expr: "q.name"},
+	{Key: "state", Header: "State", Expr: "ts.name"},
+	{Key: "priority", Header: "Priority", Expr: "tp.name"},
+	{Key: "customer_user_id", Header: "Customer User", Expr: "t.customer_user_id"},
+	{Key: "customer_id", Header: "Customer", Expr: "t.customer_id"},
+	{Key: "owner", Header: "Owner", Expr: "ou.login"},
+	{Key: "responsible", Header: "Responsible", Expr: "ru.login"},
+	{Key: "created", Header: "Created", Expr: "t.create_time"},
+	{Key: "changed", Header: "Changed", Expr: "t.change_time"},
+}
+
+// ticketExportDefaultColumns is used when no columns are requested.
+var ticketExportDefaultColumns = []string{"tn", "title", "queue", "state", "priority", "customer_user_id", "created", "changed"}
+
+// ticketExportFilters holds the list filters applied to an export.
+type ticketExportFilters struct {
+	Status         string // open, pending, closed, not_closed or all
+	QueueID        int
+	PriorityID     int
+	CustomerUserID string
+	ResponsibleID  int
+	Unassigned     bool
+	Search         string
+	Sort           string
+	Order          string
+}
+
+// parseTicketExportFilters reads filters from the query string. It accepts
+// both the REST list parameters (queue_id, assigned_user_id) and the agent
+// ticket list form fields (queue, assignee), so the list page can pass its
+// current filters straight through.
+func parseTicketExportFilters(c *gin.Context, userID int) (ticketExportFilters, error) {
+	f := ticketExportFilters{
+		Status:         c.DefaultQuery("status", "all"),
+		CustomerUserID: c.Query("customer_user_id"),
+		Search:         strings.TrimSpace(c.Query("search")),
+		Sort:           c.DefaultQuery("sort", "created"),
+		Order:          strings.ToLower(c.DefaultQuery("order", "desc")),
+	}
+
+	switch f.Status {
+	case "all", "open", "pending", "closed", "not_closed":
+	default:
+		return f, fmt.Errorf("invalid status %q", f.Status)
+	}
+
+	queue := c.Query("queue_id")
+	if queue == "" && c.Query("queue") != "all" {
+		queue = c.Query("queue")
+	}
+	if queue != "" {
+		id, err := strconv.Atoi(queue)
+		if err != nil || id <= 0 {
+			return f, fmt.Errorf("invalid queue id %q", queue)
+		}
+		f.QueueID = id
+	}
+
+	if v := c.Query("priority_id"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil || id <= 0 {
+			return f, fmt.Errorf("invalid priority id %q", v)
+		}
+		f.PriorityID = id
+	}
+
+	if v := c.Query("assigned_user_id"); v != "" {
+		id, err := strconv.Atoi(v)
+		if err != nil || id <= 0 {
+			return f, fmt.Errorf("invalid assigned user id %q", v)
+		}
+		f.ResponsibleID = id
+	}
+	switch c.Query("assignee") {
+	case "me":
+		f.ResponsibleID = userID
+	case "unassigned":
+		f.Unassigned = true
+	}
+
+	if f.Order != "asc" && f.Order != "desc" {
+		f.Order = "desc"
+	}
+	return f, nil
+}
+
+// parseTicketExportColumns resolves a comma-separated column list. Dynamic
+// fields are selected as "DynamicField_<Name>" and resolved with lookupDF;
+// only valid ticket fields are accepted.
+func parseTicketExportColumns(spec string, lookupDF func(name string) (*DynamicField, error)) ([]ticketExportColumn, error) {
+	keys := ticketExportDefaultColumns
+	if strings.TrimSpace(spec) != "" {
+		keys = strings.Split(spec, ",")
+	}
+
+	columns := make([]ticketExportColumn, 0, len(keys))
+	seen := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		key = strings.TrimSpace(key)
+		if key == "" || seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		if name, ok := strings.CutPrefix(key, ticketExportDFPrefix); ok {
+			df, err := lookupDF(name)
+			if err != nil {
+				return nil, err
+			}
+			if df == nil || df.ObjectType != DFObjectTicket || !df.IsActive() {
+				return nil, fmt.Errorf("unknown ticket dynamic field %q", name)
+			}
+			header := df.Label
+			if header == "" {
+				header = df.Name
+			}
+			columns = append(columns, ticketExportColumn{
+				Key:    key,
+				Header: header,
+				Expr:   dynamicFieldExportExpr(df),
+				Args:   []interface{}{df.ID},
+			})
+			continue
+		}
+
+		col, ok := ticketExportStaticColumn(key)
+		if !ok {
+			return nil, fmt.Errorf("unknown column %q", key)
+		}
+		columns = append(columns, col)
+	}
+
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("no columns selected")
+	}
+	return columns, nil
+}
+
+func ticketExportStaticColumn(key string) (ticketExportColumn, bool) {
+	for _, col := range ticketExportStaticColumns {
+		if col.Key == key {
+			return col, true
+		}
+	}
+	return ticketExportColumn{}, false
+}
+
+// dynamicFieldExportExpr selects a ticket's value for a dynamic field as a
+// correlated subquery, joining multiselect values into one cell.
+func dynamicFieldExportExpr(df *DynamicField) string {
+	value := "MIN(dfv." + df.GetValueColumn() + ")"
+	if df.FieldType == DFTypeMultiselect || df.FieldType == DFTypeWebserviceMultiselect {
+		if database.IsMySQL() {
+			value = "GROUP_CONCAT(dfv.value_text ORDER BY dfv.id SEPARATOR ', ')"
+		} else {
+			value = "STRING_AGG(dfv.value_text, ', ' ORDER BY dfv.id)"
+		}
+	}
+	return "(SELECT " + value + " FROM dynamic_field_value dfv WHERE dfv.object_id = t.id AND dfv.field_id = ?)"
+}
+
+// buildTicketExportQuery builds the export SELECT. queueIDs restricts the
+// result to those queues unless allQueues is set; extraWhere is appended to
+// the WHERE clause (e.g. dynamic field filters).
+func buildTicketExportQuery(columns []ticketExportColumn, f ticketExportFilters, allQueues bool, queueIDs []uint, extraWhere string, extraArgs []interface{}) (string, []interface{}) {
+	exprs := make([]string, len(columns))
+	var args []interface{}
+	for i, col := range columns {
+		exprs[i] = col.Expr
+		args = append(args, col.Args...)
+	}
+
+	query := "SELECT " + strings.Join(exprs, ", ") + `
+		FROM ticket t
+		LEFT JOIN queue q ON t.queue_id = q.id
+		LEFT JOIN ticket_state ts ON t.ticket_state_id = ts.id
+		LEFT JOIN ticket_priority tp ON t.ticket_priority_id = tp.id
+		LEFT JOIN users ou ON t.user_id = ou.id
+		LEFT JOIN users ru ON t.responsible_user_id = ru.id
+		WHERE 1=1`
+
+	if !allQueues {
+		if len(queueIDs) == 0 {
+			query += " AND 1=0"
+		} else {
+			placeholders := make([]string, len(queueIDs))
+			for i, qid := range queueIDs {
+				placeholders[i] = "?"
+				args = append(args, qid)
+			}
+			query += " AND t.queue_id IN (" + strings.Join(placeholders, ",") + ")"
+		}
+	}
+
+	switch f.Status {
+	case "open":
+		query += " AND t.ticket_state_id IN (SELECT id FROM ticket_state WHERE type_id IN (1, 2))"
+	case "pending":
+		query += " AND t.ticket_state_id IN (SELECT id FROM ticket_state WHERE type_id IN (4, 5))"
+	case "closed":
+		query += " AND t.ticket_state_id IN (SELECT id FROM ticket_state WHERE type_id = 3)"
+	case "not_closed":
+		query += " AND t.ticket_state_id NOT IN (SELECT id FROM ticket_state WHERE type_id = 3)"
+	}
+
+	if f.QueueID > 0 {
+		query += " AND t.queue_id = ?"
+		args = append(args, f.QueueID)
+	}
+	if f.PriorityID > 0 {
+		query += " AND t.ticket_priority_id = ?"
+		args = append(args, f.PriorityID)
+	}
+	if f.CustomerUserID != "" {
+		query += " AND t.customer_user_id = ?"
+		args = append(args, f.CustomerUserID)
+	}
+	if f.Unassigned {
+		query += " AND t.responsible_user_id IS NULL"
+	} else if f.ResponsibleID > 0 {
+		query += " AND t.responsible_user_id = ?"
+		args = append(args, f.ResponsibleID)
+	}
+	if f.Search != "" {
+		pattern := "%" + f.Search + "%"
+		query += " AND (LOWER(t.tn) LIKE LOWER(?) OR LOWER(t.title) LIKE LOWER(?) OR LOWER(t.customer_user_id) LIKE LOWER(?))"
+		args = append(args, pattern, pattern, pattern)
+	}
+
+	if extraWhere != "" {
+		query += extraWhere
+		args = append(args, extraArgs...)
+	}
+
+	sortColumn := "t.create_time"
+	switch f.Sort {
+	case "updated", "change_time":
+		sortColumn = "t.change_time"
+	case "priority":
+		sortColumn = "t.ticket_priority_id"
+	case "tn":
+		sortColumn = "t.tn"
+	case "title":
+		sortColumn = "t.title"
+	}
+	query += fmt.Sprintf(" ORDER BY %s %s, t.id %s", sortColumn, strings.ToUpper(f.Order), strings.ToUpper(f.Order))
+
+	return query, args
+}
+
+// ticketExportWriter writes export rows in one output format.
+type ticketExportWriter interface {
+	WriteRow(values []string) error
+	Flush() error
+	Close() error
+}
+
+type csvTicketExportWriter struct {
+	w  *csv.Writer
+	rw gin.ResponseWriter
+}
+
+func (e *csvTicketExportWriter) WriteRow(values []string) error {
+	safe := make([]string, len(values))
+	for i, v := range values {
+		safe[i] = csvSafeCell(v)
+	}
+	return e.w.Write(safe)
+}
+
+func (e *csvTicketExportWriter) Flush() error {
+	e.w.Flush()
+	e.rw.Flush()
+	return e.w.Error()
+}
+
+func (e *csvTicketExportWriter) Close() error { return e.Flush() }
+
+// csvSafeCell neutralises values a spreadsheet would evaluate as a formula.
+func csvSafeCell(v string) string {
+	if v != "" && strings.ContainsRune("=+-@\t\r", rune(v[0])) {
+		return "'" + v
+	}
+	return v
+}
+
+// xlsxTicketExportWriter uses excelize's stream writer, which spills rows to
+// a temporary file instead of holding the sheet in memory.
+type xlsxTicketExportWriter struct {
+	file *excelize.File
+	sw   *excelize.StreamWriter
+	rw   gin.ResponseWriter
+	row  int
+}
+
+func newXLSXTicketExportWriter(rw gin.ResponseWriter) (*xlsxTicketExportWriter, error) {
+	f := excelize.NewFile()
+	sw, err := f.NewStreamWriter("Sheet1")
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return &xlsxTicketExportWriter{file: f, sw: sw, rw: rw}, nil
+}
+
+func (e *xlsxTicketExportWriter) WriteRow(values []string) error {
+	e.row++
+	cells := make([]interface{}, len(values))
+	for i, v := range values {
+		cells[i] = v
+	}
+	cell, err := excelize.CoordinatesToCellName(1, e.row)
+	if err != nil {
+		return err
+	}
+	return e.sw.SetRow(cell, cells)
+}
+
+// Flush is a no-op: the workbook can only be sent once complete.
+func (e *xlsxTicketExportWriter) Flush() error { return nil }
+
+func (e *xlsxTicketExportWriter) Close() error {
+	defer e.file.Close()
+	if err := e.sw.Flush(); err != nil {
+		return err
+	}
+	return e.file.Write(e.rw)
+}
+
+// HandleExportTicketsAPI handles GET /api/v1/tickets/export.
+//
+// Streams the filtered ticket list as CSV (default) or XLSX. Columns are
+// chosen with ?columns=tn,title,DynamicField_<Name>; filters match the ticket
+// list. Results are limited to the caller's readable queues. HTMX requests
+// get an HX-Redirect to the same URL so the browser downloads the file.
+//
+//	@Summary		Export tickets
+//	@Description	Stream filtered tickets as CSV or XLSX with selectable columns
+//	@Tags			Tickets
+//	@Produce		text/csv
+//	@Param			format		query	string	false	"Export format"	Enums(csv, xlsx)	default(csv)
+//	@Param			columns		query	string	false	"Comma-separated columns, including DynamicField_<Name>"
+//	@Param			status		query	string	false	"Filter by status"	Enums(all, open, pending, closed, not_closed)
+//	@Param			queue_id	query	int		false	"Filter by queue ID"
+//	@Param			search		query	string	false	"Search in ticket number, title, customer"
+//	@Success		200
+//	@Failure		400	{object}	map[string]interface{}	"Invalid format, column or filter"
+//	@Failure		403	{object}	map[string]interface{}	"Customers cannot export tickets"
+//	@Security		BearerAuth
+//	@Router			/tickets/export [get]
+func HandleExportTicketsAPI(c *gin.Context) {
+	if kbIsCustomer(c) {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": "Ticket export is only available to agents"})
+		return
+	}
+
+	format := strings.ToLower(c.DefaultQuery("format", "csv"))
+	if format != "csv" && format != "xlsx" {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid export format. Supported: csv, xlsx"})
+		return
+	}
+
+	userID := GetUserIDFromCtx(c, 0)
+	filters, err := parseTicketExportFilters(c, userID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	db, err := database.GetDB()
+	if err != nil || db == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"success": false, "error": "Database unavailable"})
+		return
+	}
+
+	columns, err := parseTicketExportColumns(c.Query("columns"), func(name string) (*DynamicField, error) {
+		return getDynamicFieldByNameWithDB(db, name)
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	// Queue scope comes from the queue_ro middleware
+	allQueues := false
+	if v, ok := c.Get("is_queue_admin"); ok && v == true {
+		allQueues = true
+	}
+	var queueIDs []uint
+	if v, ok := c.Get("accessible_queue_ids"); ok {
+		queueIDs, _ = v.([]uint)
+	}
+	if !allQueues && len(queueIDs) == 0 {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": "You do not have access to any queues"})
+		return
+	}
+
+	// The export itself is a plain navigation; let HTMX hand it to the browser
+	if c.GetHeader("HX-Request") == "true" {
+		c.Header("HX-Redirect", c.Request.URL.RequestURI())
+		c.Status(http.StatusOK)
+		return
+	}
+
+	var dfSQL string
+	var dfArgs []interface{}
+	if dfFilters := ParseDynamicFieldFiltersFromQuery(c.Request.URL.Query()); len(dfFilters) > 0 {
+		dfSQL, dfArgs, err = BuildDynamicFieldFilterSQL(dfFilters, 0)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+			return
+		}
+	}
+
+	query, args := buildTicketExportQuery(columns, filters, allQueues, queueIDs, dfSQL, dfArgs)
+	rows, err := db.QueryContext(c.Request.Context(), database.ConvertPlaceholders(query), args...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to export tickets"})
+		return
+	}
+	defer rows.Close()
+
+	filename := "tickets-" + time.Now().Format("20060102-150405") + "." + format
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Header("Cache-Control", "no-store")
+
+	var out ticketExportWriter
+	if format == "xlsx" {
+		c.Header("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+		xw, err := newXLSXTicketExportWriter(c.Writer)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to create workbook"})
+			return
+		}
+		out = xw
+	} else {
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		out = &csvTicketExportWriter{w: csv.NewWriter(c.Writer), rw: c.Writer}
+	}
+	c.Status(http.StatusOK)
+
+	if err := streamTicketExport(rows, columns, out); err != nil {
+		// Headers are already sent; the client sees a truncated file
+		log.Printf("ticket export aborted: %v", err)
+	}
+}
+
+// streamTicketExport writes the header row and every result row to out.
+func streamTicketExport(rows *sql.Rows, columns []ticketExportColumn, out ticketExportWriter) error {
+	headers := make([]string, len(columns))
+	for i, col := range columns {
+		headers[i] = col.Header
+	}
+	if err := out.WriteRow(headers); err != nil {
+		return err
+	}
+
+	values := make([]interface{}, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	record := make([]string, len(columns))
+
+	n := 0
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return err
+		}
+		for i, v := range values {
+			record[i] = ticketExportCell(v)
+		}
+		if err := out.WriteRow(record); err != nil {
+			return err
+		}
+		if n++; n%ticketExportFlushEvery == 0 {
+			if err := out.Flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	return out.Close()
+}
+
+// ticketExportCell formats a scanned value for export.
+func ticketExportCell(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case []byte:
+		return string(val)
+	case string:
+		return val
+	case time.Time:
+		return val.Format("2006-01-02 15:04:05")
+	default:
+		return fmt.Sprint(val)
+	}
+}
@@ -0,0 +1,194 @@
+package api
+
+import (
+	"encoding/csv"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xuri/excelize/v2"
+)
+
+func exportTestDF(name string) (*DynamicField, error) {
+	switch name {
+	case "Product":
+		return &DynamicField{ID: 7, Name: "Product", Label: "Product Line", FieldType: DFTypeDropdown, ObjectType: DFObjectTicket, ValidID: 1}, nil
+	case "Tags":
+		return &DynamicField{ID: 8, Name: "Tags", FieldType: DFTypeMultiselect, ObjectType: DFObjectTicket, ValidID: 1}, nil
+	case "CustomerTier":
+		return &DynamicField{ID: 9, Name: "CustomerTier", FieldType: DFTypeText, ObjectType: DFObjectCustomerUser, ValidID: 1}, nil
+	case "Retired":
+		return &DynamicField{ID: 10, Name: "Retired", FieldType: DFTypeText, ObjectType: DFObjectTicket, ValidID: 2}, nil
+	}
+	return nil, nil
+}
+
+func TestParseTicketExportColumns(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		cols, err := parseTicketExportColumns("", exportTestDF)
+		require.NoError(t, err)
+		require.Len(t, cols, len(ticketExportDefaultColumns))
+		assert.Equal(t, "tn", cols[0].Key)
+	})
+
+	t.Run("static and dynamic fields", func(t *testing.T) {
+		cols, err := parseTicketExportColumns("tn, title,DynamicField_Product,tn,DynamicField_Tags", exportTestDF)
+		require.NoError(t, err)
+		require.Len(t, cols, 4, "duplicates are dropped")
+		assert.Equal(t, "Product Line", cols[2].Header)
+		assert.Equal(t, []interface{}{7}, cols[2].Args)
+		assert.Contains(t, cols[2].Expr, "MIN(dfv.value_text)")
+		assert.Equal(t, "Tags", cols[3].Header, "label falls back to name")
+		assert.NotContains(t, cols[3].Expr, "MIN(", "multiselect values are aggregated")
+	})
+
+	for name, spec := range map[string]string{
+		"unknown column":        "tn,password",
+		"unknown dynamic field": "DynamicField_Missing",
+		"non-ticket field":      "DynamicField_CustomerTier",
+		"invalid dynamic field": "DynamicField_Retired",
+		"only separators":       " , ,",
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := parseTicketExportColumns(spec, exportTestDF)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestParseTicketExportFilters(t *testing.T) {
+	parse := func(query string) (ticketExportFilters, error) {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodGet, "/api/v1/tickets/export?"+query, nil)
+		return parseTicketExportFilters(c, 42)
+	}
+
+	f, err := parse("queue=3&assignee=me&status=not_closed&order=ASC")
+	require.NoError(t, err)
+	assert.Equal(t, 3, f.QueueID)
+	assert.Equal(t, 42, f.ResponsibleID)
+	assert.Equal(t, "not_closed", f.Status)
+	assert.Equal(t, "asc", f.Order)
+
+	f, err = parse("queue=all&assignee=unassigned&queue_id=5")
+	require.NoError(t, err)
+	assert.Equal(t, 5, f.QueueID, "queue_id wins over the list form field")
+	assert.True(t, f.Unassigned)
+
+	for _, bad := range []string{"status=bogus", "queue_id=x", "priority_id=0", "assigned_user_id=-1"} {
+		_, err := parse(bad)
+		assert.Error(t, err, bad)
+	}
+}
+
+func TestBuildTicketExportQuery(t *testing.T) {
+	cols, err := parseTicketExportColumns("tn,DynamicField_Product", exportTestDF)
+	require.NoError(t, err)
+
+	t.Run("restricted to accessible queues", func(t *testing.T) {
+		f := ticketExportFilters{Status: "open", QueueID: 2, Search: "printer", Sort: "title", Order: "asc"}
+		query, args := buildTicketExportQuery(cols, f, false, []uint{1, 2}, " AND EXISTS (x = ?)", []interface{}{"df"})
+
+		assert.Contains(t, query, "t.queue_id IN (?,?)")
+		assert.Contains(t, query, "type_id IN (1, 2)")
+		assert.Contains(t, query, "ORDER BY t.title ASC")
+		assert.NotContains(t, query, "LIMIT")
+		// Select args come first, then the WHERE clause in order
+		assert.Equal(t, []interface{}{7, uint(1), uint(2), 2, "%printer%", "%printer%", "%printer%", "df"}, args)
+	})
+
+	t.Run("no accessible queues matches nothing", func(t *testing.T) {
+		query, _ := buildTicketExportQuery(cols, ticketExportFilters{Order: "desc"}, false, nil, "", nil)
+		assert.Contains(t, query, "AND 1=0")
+	})
+
+	t.Run("queue admin sees all queues", func(t *testing.T) {
+		query, _ := buildTicketExportQuery(cols, ticketExportFilters{Unassigned: true, Order: "desc"}, true, nil, "", nil)
+		assert.NotContains(t, query, "t.queue_id IN")
+		assert.Contains(t, query, "t.responsible_user_id IS NULL")
+	})
+}
+
+func TestTicketExportWriters(t *testing.T) {
+	rows := [][]string{{"Ticket#", "Title"}, {"2025010110000001", "=HYPERLINK(\"x\")"}}
+
+	t.Run("csv", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		out := &csvTicketExportWriter{w: csv.NewWriter(c.Writer), rw: c.Writer}
+		for _, r := range rows {
+			require.NoError(t, out.WriteRow(r))
+		}
+		require.NoError(t, out.Close())
+
+		records, err := csv.NewReader(strings.NewReader(w.Body.String())).ReadAll()
+		require.NoError(t, err)
+		require.Len(t, records, 2)
+		assert.Equal(t, "'=HYPERLINK(\"x\")", records[1][1], "formulas are neutralised")
+	})
+
+	t.Run("xlsx", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		out, err := newXLSXTicketExportWriter(c.Writer)
+		require.NoError(t, err)
+		for _, r := range rows {
+			require.NoError(t, out.WriteRow(r))
+		}
+		require.NoError(t, out.Close())
+
+		f, err := excelize.OpenReader(w.Body)
+		require.NoError(t, err)
+		defer f.Close()
+		got, err := f.GetRows("Sheet1")
+		require.NoError(t, err)
+		assert.Equal(t, rows, got)
+	})
+}
+
+func TestTicketExportCell(t *testing.T) {
+	assert.Equal(t, "", ticketExportCell(nil))
+	assert.Equal(t, "abc", ticketExportCell([]byte("abc")))
+	assert.Equal(t, "12", ticketExportCell(int64(12)))
+	assert.Equal(t, "2025-03-04 05:06:07", ticketExportCell(time.Date(2025, 3, 4, 5, 6, 7, 0, time.UTC)))
+}
+
+func TestHandleExportTicketsAPI_Validation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name     string
+		url      string
+		customer bool
+		wantCode int
+		wantBody string
+	}{
+		{"customer forbidden", "/api/v1/tickets/export", true, http.StatusForbidden, "only available to agents"},
+		{"invalid format", "/api/v1/tickets/export?format=pdf", false, http.StatusBadRequest, "Invalid export format"},
+		{"invalid status", "/api/v1/tickets/export?status=bogus", false, http.StatusBadRequest, "invalid status"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.GET("/api/v1/tickets/export", func(c *gin.Context) {
+				c.Set("user_id", 1)
+				if tt.customer {
+					c.Set("user_role", "Customer")
+				}
+				HandleExportTicketsAPI(c)
+			})
+
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.url, nil))
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			assert.Contains(t, w.Body.String(), tt.wantBody)
+		})
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"github.com/gin-gonic/gin"
 
 	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/service"
 	"github.com/goatkit/goatflow/internal/services"
 )
 
@@ -231,8 +232,8 @@ func HandleGetTicketAPI(c *gin.Context) {
 	// Customers have separate isolation logic (company isolation)
 	userRole, _ := c.Get("user_role")
 	isCustomer := userRole == "Customer"
+	userID := 1
 	if !isCustomer {
-		userID := 1
 		if ctxUserID, exists := c.Get("user_id"); exists {
 			switch v := ctxUserID.(type) {
 			case int:
@@ -320,6 +321,10 @@ func HandleGetTicketAPI(c *gin.Context) {
 	if ticket.ResponsibleUserID.Valid {
 		response["responsible_user_id"] = ticket.ResponsibleUserID.Int32
 	}
+	if isCustomer {
+		loadCustomerStates(c, db).apply(response, "state", ticket.StateID)
+	}
+	addTicketRating(c.Request.Context(), db, ticket.ID, response)
 
 	// Get article count
 	var articleCount int
@@ -331,6 +336,14 @@ func HandleGetTicketAPI(c *gin.Context) {
 		response["article_count"] = articleCount
 	}
 
+	// Include linked tickets (parent/child, relates-to, duplicates) the agent
+	// can read. Links can cross companies, so customers do not get them.
+	if !isCustomer {
+		if links, err := service.NewTicketLinkService(db).ListLinksForUser(c.Request.Context(), int(ticketID), userID); err == nil {
+			response["links"] = links
+		}
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"data":    response,
@@ -0,0 +1,227 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/history"
+	"github.com/goatkit/goatflow/internal/repository"
+	"github.com/goatkit/goatflow/internal/service"
+	"github.com/goatkit/goatflow/internal/services"
+)
+
+// ticketLinkAPIRequest is the JSON body accepted by HandleCreateTicketLinkAPI.
+type ticketLinkAPIRequest struct {
+	TargetTicketID int    `json:"target_ticket_id" binding:"required,min=1"`
+	Type           string `json:"type" binding:"required"`
+}
+
+// HandleListTicketLinksAPI handles GET /api/v1/tickets/:id/links.
+//
+//	@Summary		List ticket links
+//	@Description	List parent/child, relates-to and duplicate links of a ticket
+//	@Tags			Tickets
+//	@Produce		json
+//	@Param			id	path		int	true	"Ticket ID"
+//	@Success		200	{object}	map[string]interface{}	"Linked tickets"
+//	@Failure		400	{object}	map[string]interface{}	"Invalid ticket ID"
+//	@Security		BearerAuth
+//	@Router			/tickets/{id}/links [get]
+func HandleListTicketLinksAPI(c *gin.Context) {
+	ticketID, err := strconv.Atoi(c.Param("id"))
+	if err != nil || ticketID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid ticket ID"})
+		return
+	}
+
+	db, err := database.GetDB()
+	if err != nil || db == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"success": false, "error": "Database unavailable"})
+		return
+	}
+
+	// Only list linked tickets the agent can read; queue admins see all.
+	linkSvc := service.NewTicketLinkService(db)
+	var links []service.LinkedTicket
+	if isAdmin, _ := c.Get("is_queue_admin"); isAdmin == true {
+		links, err = linkSvc.ListLinks(c.Request.Context(), ticketID)
+	} else {
+		links, err = linkSvc.ListLinksForUser(c.Request.Context(), ticketID, GetUserIDFromCtx(c, 1))
+	}
+	if err != nil {
+		log.Printf("links api: listing links of ticket %d failed: %v", ticketID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to load links"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"ticket_id": ticketID,
+			"count":     len(links),
+			"links":     links,
+		},
+	})
+}
+
+// HandleCreateTicketLinkAPI handles POST /api/v1/tickets/:id/links.
+// The ticket in the path is the link source: the parent for parent_child and
+// the duplicate for duplicates.
+//
+//	@Summary		Link tickets
+//	@Description	Create a typed link (parent_child, relates_to, duplicates) to another ticket
+//	@Tags			Tickets
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int		true	"Source ticket ID"
+//	@Param			link	body		object	true	"Link data (target_ticket_id, type)"
+//	@Success		201		{object}	map[string]interface{}	"Link created"
+//	@Failure		400		{object}	map[string]interface{}	"Invalid request"
+//	@Failure		404		{object}	map[string]interface{}	"Target ticket not found"
+//	@Failure		409		{object}	map[string]interface{}	"Link exists or would create a cycle"
+//	@Security		BearerAuth
+//	@Router			/tickets/{id}/links [post]
+func HandleCreateTicketLinkAPI(c *gin.Context) {
+	sourceID, err := strconv.Atoi(c.Param("id"))
+	if err != nil || sourceID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid ticket ID"})
+		return
+	}
+
+	var req ticketLinkAPIRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid link request: " + err.Error()})
+		return
+	}
+	if _, err := service.ResolveLinkType(req.Type); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "type must be one of parent_child, relates_to, duplicates",
+		})
+		return
+	}
+	if req.TargetTicketID == sourceID {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": service.ErrSelfLink.Error()})
+		return
+	}
+
+	db, err := database.GetDB()
+	if err != nil || db == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"success": false, "error": "Database unavailable"})
+		return
+	}
+
+	userID := GetUserIDFromCtx(c, 1)
+	ticketRepo := repository.NewTicketRepository(db)
+
+	// The route middleware only checked the source ticket; the target must at
+	// least be readable. Return 404 to avoid revealing its existence.
+	target, err := ticketRepo.GetByID(uint(req.TargetTicketID))
+	if err != nil || target == nil {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Target ticket not found"})
+		return
+	}
+	if isAdmin, _ := c.Get("is_queue_admin"); isAdmin != true {
+		canRead, err := services.NewPermissionService(db).CanReadQueue(userID, target.QueueID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to check permissions"})
+			return
+		}
+		if !canRead {
+			c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Target ticket not found"})
+			return
+		}
+	}
+
+	linkSvc := service.NewTicketLinkService(db)
+	if err := linkSvc.CreateLink(c.Request.Context(), sourceID, target.ID, req.Type, userID); err != nil {
+		switch {
+		case errors.Is(err, service.ErrLinkExists), errors.Is(err, service.ErrLinkCycle):
+			c.JSON(http.StatusConflict, gin.H{"success": false, "error": err.Error()})
+		case errors.Is(err, service.ErrUnknownLinkType):
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+		default:
+			log.Printf("links api: linking %d -> %d failed: %v", sourceID, target.ID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to create link"})
+		}
+		return
+	}
+
+	typeName, _ := service.ResolveLinkType(req.Type)
+	recorder := history.NewRecorder(ticketRepo)
+	msg := fmt.Sprintf("Linked to ticket %s (%s)", target.TicketNumber, typeName)
+	_ = recorder.Record(c.Request.Context(), nil, sourceID, nil, history.TypeMisc, msg, userID) //nolint:errcheck // Best effort
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"data": gin.H{
+			"source_ticket_id": sourceID,
+			"target_ticket_id": target.ID,
+			"type":             service.LinkTypeAlias(typeName),
+		},
+	})
+}
+
+// HandleDeleteTicketLinkAPI handles DELETE /api/v1/tickets/:id/links/:target_id?type=.
+//
+//	@Summary		Unlink tickets
+//	@Description	Remove a link between two tickets
+//	@Tags			Tickets
+//	@Produce		json
+//	@Param			id			path		int		true	"Ticket ID"
+//	@Param			target_id	path		int		true	"Linked ticket ID"
+//	@Param			type		query		string	true	"Link type (parent_child, relates_to, duplicates)"
+//	@Success		200			{object}	map[string]interface{}	"Link removed"
+//	@Failure		400			{object}	map[string]interface{}	"Invalid request"
+//	@Failure		404			{object}	map[string]interface{}	"Link not found"
+//	@Security		BearerAuth
+//	@Router			/tickets/{id}/links/{target_id} [delete]
+func HandleDeleteTicketLinkAPI(c *gin.Context) {
+	sourceID, err := strconv.Atoi(c.Param("id"))
+	if err != nil || sourceID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid ticket ID"})
+		return
+	}
+	targetID, err := strconv.Atoi(c.Param("target_id"))
+	if err != nil || targetID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid target ticket ID"})
+		return
+	}
+	linkType := c.Query("type")
+	if _, err := service.ResolveLinkType(linkType); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"error":   "type must be one of parent_child, relates_to, duplicates",
+		})
+		return
+	}
+
+	db, err := database.GetDB()
+	if err != nil || db == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"success": false, "error": "Database unavailable"})
+		return
+	}
+
+	if err := service.NewTicketLinkService(db).DeleteLink(c.Request.Context(), sourceID, targetID, linkType); err != nil {
+		if errors.Is(err, service.ErrLinkNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Link not found"})
+			return
+		}
+		log.Printf("links api: unlinking %d -> %d failed: %v", sourceID, targetID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to delete link"})
+		return
+	}
+
+	userID := GetUserIDFromCtx(c, 1)
+	recorder := history.NewRecorder(repository.NewTicketRepository(db))
+	msg := fmt.Sprintf("Removed link to ticket %d", targetID)
+	_ = recorder.Record(c.Request.Context(), nil, sourceID, nil, history.TypeMisc, msg, userID) //nolint:errcheck // Best effort
+
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
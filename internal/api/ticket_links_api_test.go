@@ -0,0 +1,81 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandleCreateTicketLinkAPI_Validation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name      string
+		ticketID  string
+		body      string
+		wantError string
+	}{
+		{
+			name:      "invalid ticket id",
+			ticketID:  "abc",
+			body:      `{"target_ticket_id": 2, "type": "relates_to"}`,
+			wantError: "Invalid ticket ID",
+		},
+		{
+			name:      "missing target",
+			ticketID:  "1",
+			body:      `{"type": "relates_to"}`,
+			wantError: "Invalid link request",
+		},
+		{
+			name:      "unknown type",
+			ticketID:  "1",
+			body:      `{"target_ticket_id": 2, "type": "blocks"}`,
+			wantError: "type must be one of",
+		},
+		{
+			name:      "self link",
+			ticketID:  "3",
+			body:      `{"target_ticket_id": 3, "type": "parent_child"}`,
+			wantError: "cannot be linked to itself",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.POST("/api/v1/tickets/:id/links", HandleCreateTicketLinkAPI)
+
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/tickets/"+tt.ticketID+"/links", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Contains(t, w.Body.String(), tt.wantError)
+		})
+	}
+}
+
+func TestHandleDeleteTicketLinkAPI_Validation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.DELETE("/api/v1/tickets/:id/links/:target_id", HandleDeleteTicketLinkAPI)
+
+	for path, wantError := range map[string]string{
+		"/api/v1/tickets/1/links/x?type=relates_to": "Invalid target ticket ID",
+		"/api/v1/tickets/1/links/2":                 "type must be one of",
+	} {
+		req := httptest.NewRequest(http.MethodDelete, path, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, path)
+		assert.Contains(t, w.Body.String(), wantError, path)
+	}
+}
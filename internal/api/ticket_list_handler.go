@@ -1,6 +1,7 @@
 package api
 
 import (
+	"database/sql"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -44,13 +45,19 @@ type PaginationInfo struct {
 //	@Param			status				query		string	false	"Filter by status"					Enums(open, closed, pending)
 //	@Param			queue_id			query		int		false	"Filter by queue ID"
 //	@Param			priority_id			query		int		false	"Filter by priority ID"
+//	@Param			type_id				query		int		false	"Filter by ticket type ID"
 //	@Param			customer_user_id	query		string	false	"Filter by customer user ID/login"
 //	@Param			assigned_user_id	query		int		false	"Filter by assigned agent user ID"
 //	@Param			search				query		string	false	"Search in ticket number, title, customer"
 //	@Param			sort				query		string	false	"Sort field"						Enums(created, updated, priority, tn)	default(created)
 //	@Param			order				query		string	false	"Sort order"						Enums(asc, desc)						default(desc)
 //	@Param			include				query		string	false	"Include related data (comma-separated: article_count, last_article)"
+//	@Param			cursor				query		string	false	"Cursor from pagination.next_cursor; pass it empty to start cursor pagination"
+//	@Param			fields				query		string	false	"Comma-separated fields to return (id is always returned)"
+//	@Param			expand				query		string	false	"Expand related resources (comma-separated: queue, owner, responsible, last_article)"
 //	@Success		200					{object}	map[string]interface{}	"List of tickets with pagination"
+//	@Header			200					{string}	Link					"RFC 5988 links to the first, previous, next and last pages"
+//	@Failure		400					{object}	map[string]interface{}	"Invalid cursor, fields or expand parameter"
 //	@Failure		401					{object}	map[string]interface{}	"Unauthorized"
 //	@Failure		500					{object}	map[string]interface{}	"Internal server error"
 //	@Security		BearerAuth
@@ -138,6 +145,12 @@ func HandleListTicketsAPI(c *gin.Context) {
 		}
 	}
 
+	if typeID := c.Query("type_id"); typeID != "" {
+		if tid, err := strconv.Atoi(typeID); err == nil {
+			filters["type_id"] = tid
+		}
+	}
+
 	if customerUserID := c.Query("customer_user_id"); customerUserID != "" {
 		filters["customer_user_id"] = customerUserID
 	}
@@ -156,19 +169,12 @@ func HandleListTicketsAPI(c *gin.Context) {
 	sortOrder := c.DefaultQuery("order", "desc")
 
 	// Map sort field names to database columns
-	sortColumn := "t.create_time"
-	switch sortField {
-	case "created":
-		sortColumn = "t.create_time"
-	case "updated":
-		sortColumn = "t.change_time"
-	case "priority":
-		sortColumn = "t.ticket_priority_id"
-	case "tn":
-		sortColumn = "t.tn"
-	case "title":
-		sortColumn = "t.title"
+	sort, ok := ticketListSorts[sortField]
+	if !ok {
+		sortField = "created"
+		sort = ticketListSorts[sortField]
 	}
+	sortColumn := sort.Column
 
 	// Validate sort order
 	if sortOrder != "asc" && sortOrder != "desc" {
@@ -225,6 +231,22 @@ func HandleListTicketsAPI(c *gin.Context) {
 		}
 	}
 
+	fields, err := parseListNames(c, "fields", ticketListFields)
+	if err != nil {
+		listParamError(c, err)
+		return
+	}
+	expand, err := parseListNames(c, "expand", ticketListExpansions)
+	if err != nil {
+		listParamError(c, err)
+		return
+	}
+	cursorMode, cursor, err := listCursorParam(c, sortField, sortOrder)
+	if err != nil {
+		listParamError(c, err)
+		return
+	}
+
 	// Get database connection
 	db, err := database.GetDB()
 	if err != nil || db == nil {
@@ -327,6 +349,8 @@ func HandleListTicketsAPI(c *gin.Context) {
 			ts.name as state_name,
 			t.ticket_priority_id as priority_id,
 			tp.name as priority_name,
+			t.type_id,
+			tt.name as type_name,
 			t.customer_user_id,
 			t.customer_id,
 			t.user_id,
@@ -337,6 +361,7 @@ func HandleListTicketsAPI(c *gin.Context) {
 		LEFT JOIN queue q ON t.queue_id = q.id
 		LEFT JOIN ticket_state ts ON t.ticket_state_id = ts.id
 		LEFT JOIN ticket_priority tp ON t.ticket_priority_id = tp.id
+		LEFT JOIN ticket_type tt ON t.type_id = tt.id
 		WHERE 1=1`
 
 	args := []interface{}{}
@@ -361,6 +386,11 @@ func HandleListTicketsAPI(c *gin.Context) {
 		args = append(args, priorityID)
 	}
 
+	if typeID, ok := filters["type_id"].(int); ok {
+		query += " AND t.type_id = ?"
+		args = append(args, typeID)
+	}
+
 	if customerUserID, ok := filters["customer_user_id"].(string); ok && customerUserID != "" {
 		query += " AND t.customer_user_id = ?"
 		args = append(args, customerUserID)
@@ -387,22 +417,43 @@ func HandleListTicketsAPI(c *gin.Context) {
 		args = append(args, "%"+search+"%", search)
 	}
 
-	// Get total count
-	countQuery := "SELECT COUNT(*) FROM (" + query + ") as count_query"
+	// Get total count. Cursor pages skip it; counting is what makes deep
+	// offset pages slow on large installations.
 	var total int
-	err = db.QueryRow(database.ConvertPlaceholders(countQuery), args...).Scan(&total)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"success": false,
-			"error":   "Failed to count tickets",
-		})
-		return
+	if !cursorMode {
+		countQuery := "SELECT COUNT(*) FROM (" + query + ") as count_query"
+		err = db.QueryRow(database.ConvertPlaceholders(countQuery), args...).Scan(&total)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"error":   "Failed to count tickets",
+			})
+			return
+		}
 	}
 
-	// Add sorting and pagination
-	query += fmt.Sprintf(" ORDER BY %s %s", sortColumn, strings.ToUpper(sortOrder))
-	query += " LIMIT ? OFFSET ?"
-	args = append(args, perPage, offset)
+	if cursor != nil {
+		cond, condArgs, err := sort.keyset(cursor, "t.id", sortOrder)
+		if err != nil {
+			listParamError(c, err)
+			return
+		}
+		query += " AND " + cond
+		args = append(args, condArgs...)
+	}
+
+	// Add sorting and pagination; the ticket ID keeps the order stable
+	// between tickets with equal sort values.
+	dir := strings.ToUpper(sortOrder)
+	query += fmt.Sprintf(" ORDER BY %s %s, t.id %s", sortColumn, dir, dir)
+	if cursorMode {
+		// One extra row tells whether there is a next page.
+		query += " LIMIT ?"
+		args = append(args, perPage+1)
+	} else {
+		query += " LIMIT ? OFFSET ?"
+		args = append(args, perPage, offset)
+	}
 
 	// Execute main query
 	rows, err := db.Query(database.ConvertPlaceholders(query), args...)
@@ -416,6 +467,12 @@ func HandleListTicketsAPI(c *gin.Context) {
 	defer rows.Close()
 
 	// Process results
+	// Customers see customer-facing state labels, not internal state names.
+	var states *customerStates
+	if isCustomer {
+		states = loadCustomerStates(c, db)
+	}
+
 	tickets := []map[string]interface{}{}
 	for rows.Next() {
 		var ticket struct {
@@ -428,6 +485,8 @@ func HandleListTicketsAPI(c *gin.Context) {
 			StateName         string  `json:"state_name"`
 			PriorityID        int     `json:"priority_id"`
 			PriorityName      string  `json:"priority_name"`
+			TypeID            *int    `json:"type_id"`
+			TypeName          *string `json:"type_name"`
 			CustomerUserID    *string `json:"customer_user_id"`
 			CustomerID        *string `json:"customer_id"`
 			UserID            int     `json:"user_id"`
@@ -446,6 +505,8 @@ func HandleListTicketsAPI(c *gin.Context) {
 			&ticket.StateName,
 			&ticket.PriorityID,
 			&ticket.PriorityName,
+			&ticket.TypeID,
+			&ticket.TypeName,
 			&ticket.CustomerUserID,
 			&ticket.CustomerID,
 			&ticket.UserID,
@@ -475,7 +536,17 @@ func HandleListTicketsAPI(c *gin.Context) {
 			"update_time":   ticket.UpdatedAt,
 		}
 
+		if states != nil {
+			states.apply(ticketMap, "state_name", ticket.StateID)
+		}
+
 		// Add optional fields
+		if ticket.TypeID != nil {
+			ticketMap["type_id"] = *ticket.TypeID
+		}
+		if ticket.TypeName != nil {
+			ticketMap["type_name"] = *ticket.TypeName
+		}
 		if ticket.CustomerUserID != nil {
 			ticketMap["customer_user_id"] = *ticket.CustomerUserID
 		} else {
@@ -542,10 +613,41 @@ func HandleListTicketsAPI(c *gin.Context) {
 	}
 	_ = rows.Err() //nolint:errcheck // Check for iteration errors
 
+	if len(expand) > 0 {
+		expandTicketList(c, db, tickets, expand, isCustomer)
+	}
+
+	if cursorMode {
+		nextCursor := ""
+		if len(tickets) > perPage {
+			tickets = tickets[:perPage]
+			nextCursor = sort.cursorAfter(sortField, sortOrder, tickets[perPage-1])
+		}
+		for i := range tickets {
+			tickets[i] = selectListFields(tickets[i], fields, expand)
+		}
+		setCursorLinkHeader(c, nextCursor)
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"data":    tickets,
+			"pagination": gin.H{
+				"per_page":    perPage,
+				"next_cursor": nextCursor,
+				"has_next":    nextCursor != "",
+			},
+		})
+		return
+	}
+
+	for i := range tickets {
+		tickets[i] = selectListFields(tickets[i], fields, expand)
+	}
+
 	// Calculate pagination info
 	totalPages := (total + perPage - 1) / perPage
 	hasNext := page < totalPages
 	hasPrev := page > 1
+	setPageLinkHeader(c, page, totalPages)
 
 	// Return response
 	c.JSON(http.StatusOK, TicketListResponse{
@@ -561,3 +663,153 @@ func HandleListTicketsAPI(c *gin.Context) {
 		},
 	})
 }
+
+// ticketListSorts maps the sort parameter of the ticket list to its column.
+var ticketListSorts = map[string]listSort{
+	"created":  {Column: "t.create_time", Field: "created_at", Kind: listSortTime},
+	"updated":  {Column: "t.change_time", Field: "updated_at", Kind: listSortTime},
+	"priority": {Column: "t.ticket_priority_id", Field: "priority_id", Kind: listSortInt},
+	"tn":       {Column: "t.tn", Field: "tn", Kind: listSortString},
+	"title":    {Column: "t.title", Field: "title", Kind: listSortString},
+}
+
+// ticketListFields are the fields accepted by fields= on the ticket list.
+var ticketListFields = []string{
+	"tn", "ticket_number", "title", "queue_id", "queue_name", "state_id", "state_name", "state_key", "state_category",
+	"priority_id", "priority_name", "type_id", "type_name", "customer_user_id", "customer_id",
+	"user_id", "responsible_user_id", "created_at", "updated_at", "create_time", "update_time",
+	"article_count", "last_article",
+}
+
+// ticketListExpansions are the related resources accepted by expand= on the
+// ticket list.
+var ticketListExpansions = []string{"queue", "owner", "responsible", "last_article"}
+
+// expandTicketList replaces the IDs of related resources with the resources
+// themselves, loading each kind with one query for the whole page.
+func expandTicketList(c *gin.Context, db *sql.DB, tickets []map[string]interface{}, expand map[string]bool, isCustomer bool) {
+	if len(tickets) == 0 {
+		return
+	}
+	ctx := c.Request.Context()
+
+	if expand["queue"] {
+		ids := make([]interface{}, 0, len(tickets))
+		for _, t := range tickets {
+			ids = append(ids, t["queue_id"])
+		}
+		queues := map[int]map[string]interface{}{}
+		rows, err := db.QueryContext(ctx, database.ConvertPlaceholders(
+			"SELECT id, name, group_id, valid_id FROM queue WHERE id IN ("+listPlaceholders(len(ids))+")"), ids...)
+		if err == nil {
+			for rows.Next() {
+				var id, groupID, validID int
+				var name string
+				if rows.Scan(&id, &name, &groupID, &validID) == nil {
+					queues[id] = map[string]interface{}{"id": id, "name": name, "group_id": groupID, "valid_id": validID}
+				}
+			}
+			_ = rows.Err() //nolint:errcheck // Check for iteration errors
+			rows.Close()
+		}
+		for _, t := range tickets {
+			if q, ok := queues[t["queue_id"].(int)]; ok {
+				t["queue"] = q
+			} else {
+				t["queue"] = nil
+			}
+		}
+	}
+
+	if expand["owner"] || expand["responsible"] {
+		ids := []interface{}{}
+		for _, t := range tickets {
+			ids = append(ids, t["user_id"])
+			if rid, ok := t["responsible_user_id"].(int); ok {
+				ids = append(ids, rid)
+			}
+		}
+		users := map[int]map[string]interface{}{}
+		rows, err := db.QueryContext(ctx, database.ConvertPlaceholders(
+			"SELECT id, login, first_name, last_name FROM users WHERE id IN ("+listPlaceholders(len(ids))+")"), ids...)
+		if err == nil {
+			for rows.Next() {
+				var id int
+				var login string
+				var first, last sql.NullString
+				if rows.Scan(&id, &login, &first, &last) != nil {
+					continue
+				}
+				u := map[string]interface{}{"id": id, "first_name": first.String, "last_name": last.String}
+				// Customers see who handles their ticket, not agent logins.
+				if !isCustomer {
+					u["login"] = login
+				}
+				users[id] = u
+			}
+			_ = rows.Err() //nolint:errcheck // Check for iteration errors
+			rows.Close()
+		}
+		for _, t := range tickets {
+			if expand["owner"] {
+				t["owner"] = users[t["user_id"].(int)]
+			}
+			if expand["responsible"] {
+				t["responsible"] = nil
+				if rid, ok := t["responsible_user_id"].(int); ok {
+					if u, ok := users[rid]; ok {
+						t["responsible"] = u
+					}
+				}
+			}
+		}
+	}
+
+	if expand["last_article"] {
+		ids := make([]interface{}, 0, len(tickets))
+		for _, t := range tickets {
+			ids = append(ids, t["id"])
+		}
+		visible := ""
+		if isCustomer {
+			visible = " AND a2.is_visible_for_customer = 1"
+		}
+		articles := map[int64]map[string]interface{}{}
+		rows, err := db.QueryContext(ctx, database.ConvertPlaceholders(`
+			SELECT a.ticket_id, a.id, adm.a_subject, adm.a_from, a.is_visible_for_customer, a.create_time
+			FROM article a
+			LEFT JOIN article_data_mime adm ON a.id = adm.article_id
+			WHERE a.id IN (
+				SELECT MAX(a2.id) FROM article a2
+				WHERE a2.ticket_id IN (`+listPlaceholders(len(ids))+`)`+visible+`
+				GROUP BY a2.ticket_id
+			)`), ids...)
+		if err == nil {
+			for rows.Next() {
+				var ticketID, articleID int64
+				var subject, from sql.NullString
+				var visibleForCustomer int
+				var createdAt string
+				if rows.Scan(&ticketID, &articleID, &subject, &from, &visibleForCustomer, &createdAt) != nil {
+					continue
+				}
+				articles[ticketID] = map[string]interface{}{
+					"id":                      articleID,
+					"subject":                 subject.String,
+					"from":                    from.String,
+					"is_visible_for_customer": visibleForCustomer == 1,
+					"created_at":              createdAt,
+				}
+			}
+			_ = rows.Err() //nolint:errcheck // Check for iteration errors
+			rows.Close()
+		}
+		for _, t := range tickets {
+			if a, ok := articles[t["id"].(int64)]; ok {
+				t["last_article"] = a
+			} else {
+				t["last_article"] = nil
+			}
+		}
+	}
+}
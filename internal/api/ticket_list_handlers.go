@@ -28,7 +28,7 @@ func init() {
 // handleTickets shows the tickets list page.
 func handleTickets(c *gin.Context) {
 	// Get database connection (graceful fallback to empty list)
-	db, err := database.GetDB()
+	db, err := database.GetReadDB()
 	if err != nil || db == nil {
 		// Return JSON error for database issues
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -42,6 +42,7 @@ func handleTickets(c *gin.Context) {
 	statusParam := strings.TrimSpace(c.Query("status"))
 	priorityParam := strings.TrimSpace(c.Query("priority"))
 	queueParam := strings.TrimSpace(c.Query("queue"))
+	typeParam := strings.TrimSpace(c.Query("type"))
 	search := strings.TrimSpace(c.Query("search"))
 	sortBy := c.DefaultQuery("sort", "created_desc")
 	page := queryInt(c, "page", 1)
@@ -89,6 +90,9 @@ func handleTickets(c *gin.Context) {
 	if queueParam != "" && queueParam != "all" {
 		hasActiveFilters = true
 	}
+	if typeParam != "" && typeParam != "all" {
+		hasActiveFilters = true
+	}
 	if search != "" {
 		hasActiveFilters = true
 	}
@@ -125,6 +129,15 @@ func handleTickets(c *gin.Context) {
 		}
 	}
 
+	// Apply ticket type filter
+	if typeParam != "" && typeParam != "all" {
+		typeID, _ := strconv.Atoi(typeParam) //nolint:errcheck // Defaults to 0
+		if typeID > 0 {
+			typeIDPtr := uint(typeID)
+			req.TypeID = &typeIDPtr
+		}
+	}
+
 	// Queue permission filtering is handled by middleware
 	// Use context values set by queue_ro middleware
 	isQueueAdmin := false
@@ -317,6 +330,25 @@ func handleTickets(c *gin.Context) {
 		}
 	}
 
+	// Get ticket types for filter
+	typeList := make([]gin.H, 0)
+	typeLabels := map[string]string{}
+	if types, err := repository.NewTicketTypeRepository(db).List(c.Request.Context(), false); err == nil {
+		for _, tt := range types {
+			idStr := fmt.Sprintf("%d", tt.ID)
+			typeList = append(typeList, gin.H{"id": idStr, "name": tt.Name})
+			typeLabels[idStr] = tt.Name
+		}
+	}
+	typeLabel := ""
+	if typeParam != "" && typeParam != "all" {
+		if val, ok := typeLabels[typeParam]; ok {
+			typeLabel = val
+		} else {
+			typeLabel = typeParam
+		}
+	}
+
 	queueLabel := ""
 	if queueParam != "" && queueParam != "all" {
 		if val, ok := queueLabels[queueParam]; ok {
@@ -342,6 +374,9 @@ func handleTickets(c *gin.Context) {
 		"FilterPriorityLabel": priorityLabel,
 		"FilterQueueRaw":      queueParam,
 		"FilterQueueLabel":    queueLabel,
+		"Types":               typeList,
+		"FilterTypeRaw":       typeParam,
+		"FilterTypeLabel":     typeLabel,
 		"SearchQuery":         search,
 		"QueueID":             queueParam,
 		"SortBy":              sortBy,
@@ -454,7 +489,7 @@ func handleSearchTickets(c *gin.Context) {
 	}
 
 	// Try database first
-	db, err := database.GetDB()
+	db, err := database.GetReadDB()
 	if err == nil && db != nil {
 		// Search in ticket title and number
 		results := []gin.H{}
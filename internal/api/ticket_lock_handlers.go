@@ -0,0 +1,285 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/events"
+	"github.com/goatkit/goatflow/internal/history"
+	"github.com/goatkit/goatflow/internal/repository"
+	"github.com/goatkit/goatflow/internal/service"
+)
+
+func ticketLockService(c *gin.Context) *service.TicketLockService {
+	db, err := database.GetDB()
+	if err != nil || db == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"success": false, "error": "Database unavailable"})
+		return nil
+	}
+	return service.NewTicketLockService(db, 0)
+}
+
+// ticketLockWriteError maps TicketLockService errors to responses.
+func ticketLockWriteError(c *gin.Context, err error, action string) {
+	switch {
+	case errors.Is(err, service.ErrLockTicketNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Ticket not found"})
+	case errors.Is(err, service.ErrTicketLockedByOther):
+		c.JSON(http.StatusConflict, gin.H{"success": false, "error": err.Error()})
+	case errors.Is(err, service.ErrTicketNotLocked):
+		c.JSON(http.StatusConflict, gin.H{"success": false, "error": err.Error()})
+	case errors.Is(err, service.ErrLockTakeoverDenied):
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": err.Error()})
+	default:
+		log.Printf("ticket lock api: %s failed: %v", action, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to " + action})
+	}
+}
+
+// lockTicketID returns the ticket ID resolved by the ticket access
+// middleware, falling back to the :id path parameter.
+func lockTicketID(c *gin.Context) (int, bool) {
+	if v, ok := c.Get("ticket_id"); ok {
+		if id, ok := v.(uint64); ok && id > 0 {
+			return int(id), true
+		}
+	}
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid ticket ID"})
+		return 0, false
+	}
+	return id, true
+}
+
+func lockQueryFlag(c *gin.Context, name string) bool {
+	v, _ := strconv.ParseBool(c.Query(name))
+	return v
+}
+
+// recordLockHistory writes the Lock/Unlock history entry, which also pushes
+// a ticket.updated event to agents watching the queue.
+func recordLockHistory(c *gin.Context, ticketID int, historyType, message string, userID int) {
+	db, err := database.GetDB()
+	if err != nil || db == nil {
+		return
+	}
+	recorder := history.NewRecorder(repository.NewTicketRepository(db))
+	if err := recorder.RecordByTicketID(c.Request.Context(), nil, ticketID, nil, historyType, message, userID); err != nil {
+		log.Printf("ticket lock api: history for ticket %d failed: %v", ticketID, err)
+	}
+}
+
+// HandleGetTicketLockAPI handles GET /api/v1/tickets/:id/lock.
+//
+//	@Summary		Get ticket lock
+//	@Description	Returns who holds the ticket's lock and when it expires.
+//	@Tags			Tickets
+//	@Produce		json
+//	@Param			id	path		int	true	"Ticket ID"
+//	@Success		200	{object}	map[string]interface{}	"Lock state"
+//	@Failure		404	{object}	map[string]interface{}	"Ticket not found"
+//	@Security		BearerAuth
+//	@Router			/tickets/{id}/lock [get]
+func HandleGetTicketLockAPI(c *gin.Context) {
+	ticketID, ok := lockTicketID(c)
+	if !ok {
+		return
+	}
+	svc := ticketLockService(c)
+	if svc == nil {
+		return
+	}
+	lock, err := svc.Get(c.Request.Context(), ticketID)
+	if err != nil {
+		ticketLockWriteError(c, err, "get ticket lock")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": lock})
+}
+
+// HandleLockTicketAPI handles POST /api/v1/tickets/:id/lock.
+//
+//	@Summary		Lock ticket
+//	@Description	Locks the ticket for the caller and makes them the owner, or refreshes the caller's lock. Clients refresh while an agent is composing, as locks expire after the queue's unlock timeout (default 15 minutes). With takeover=true an agent with owner permission on the queue takes over another agent's lock.
+//	@Tags			Tickets
+//	@Produce		json
+//	@Param			id			path		int		true	"Ticket ID"
+//	@Param			takeover	query		bool	false	"Take over another agent's lock"
+//	@Success		200			{object}	map[string]interface{}	"Lock state"
+//	@Failure		403			{object}	map[string]interface{}	"Takeover requires owner permission"
+//	@Failure		409			{object}	map[string]interface{}	"Locked by another agent"
+//	@Security		BearerAuth
+//	@Router			/tickets/{id}/lock [post]
+func HandleLockTicketAPI(c *gin.Context) {
+	ticketID, ok := lockTicketID(c)
+	if !ok {
+		return
+	}
+	svc := ticketLockService(c)
+	if svc == nil {
+		return
+	}
+	userID := GetUserIDFromCtx(c, 0)
+	result, err := svc.Lock(c.Request.Context(), ticketID, userID, lockQueryFlag(c, "takeover"))
+	if err != nil {
+		if errors.Is(err, service.ErrTicketLockedByOther) {
+			if lock, gerr := svc.Get(c.Request.Context(), ticketID); gerr == nil {
+				c.JSON(http.StatusConflict, gin.H{"success": false, "error": err.Error(), "data": lock})
+				return
+			}
+		}
+		ticketLockWriteError(c, err, "lock ticket")
+		return
+	}
+
+	switch {
+	case result.PreviousOwnerID > 0:
+		recordLockHistory(c, ticketID, history.TypeLock,
+			fmt.Sprintf("Lock taken over from agent %d", result.PreviousOwnerID), userID)
+	case !result.Refreshed:
+		recordLockHistory(c, ticketID, history.TypeLock, "Locked", userID)
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": result})
+}
+
+// HandleUnlockTicketAPI handles DELETE /api/v1/tickets/:id/lock.
+//
+//	@Summary		Unlock ticket
+//	@Description	Releases the caller's lock. With force=true an agent with owner permission on the queue releases another agent's lock.
+//	@Tags			Tickets
+//	@Produce		json
+//	@Param			id		path		int		true	"Ticket ID"
+//	@Param			force	query		bool	false	"Release another agent's lock"
+//	@Success		200		{object}	map[string]interface{}	"Unlocked"
+//	@Failure		403		{object}	map[string]interface{}	"Owner permission required"
+//	@Failure		409		{object}	map[string]interface{}	"Locked by another agent or not locked"
+//	@Security		BearerAuth
+//	@Router			/tickets/{id}/lock [delete]
+func HandleUnlockTicketAPI(c *gin.Context) {
+	ticketID, ok := lockTicketID(c)
+	if !ok {
+		return
+	}
+	svc := ticketLockService(c)
+	if svc == nil {
+		return
+	}
+	userID := GetUserIDFromCtx(c, 0)
+	holder, err := svc.Unlock(c.Request.Context(), ticketID, userID, lockQueryFlag(c, "force"))
+	if err != nil {
+		ticketLockWriteError(c, err, "unlock ticket")
+		return
+	}
+
+	message := "Unlocked"
+	if holder > 0 && holder != userID {
+		message = fmt.Sprintf("Lock of agent %d released", holder)
+	}
+	recordLockHistory(c, ticketID, history.TypeUnlock, message, userID)
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Ticket unlocked"})
+}
+
+// presenceRequest is the heartbeat body accepted by HandleTicketPresenceAPI.
+type presenceRequest struct {
+	Composing bool `json:"composing"`
+}
+
+// HandleGetTicketPresenceAPI handles GET /api/v1/tickets/:id/presence.
+//
+//	@Summary		List ticket viewers
+//	@Description	Returns the agents currently viewing the ticket.
+//	@Tags			Tickets
+//	@Produce		json
+//	@Param			id	path		int	true	"Ticket ID"
+//	@Success		200	{object}	map[string]interface{}	"Viewers"
+//	@Security		BearerAuth
+//	@Router			/tickets/{id}/presence [get]
+func HandleGetTicketPresenceAPI(c *gin.Context) {
+	ticketID, ok := lockTicketID(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": events.DefaultPresence().Viewers(ticketID)})
+}
+
+// HandleTicketPresenceAPI handles POST /api/v1/tickets/:id/presence.
+//
+//	@Summary		Report viewing a ticket
+//	@Description	Heartbeat sent while an agent has the ticket open; viewers drop out after 60 seconds without one. Changes are pushed as ticket.presence events on /events/stream.
+//	@Tags			Tickets
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int		true	"Ticket ID"
+//	@Param			body	body		object	false	"{\"composing\": true} while the reply form is open"
+//	@Success		200		{object}	map[string]interface{}	"Viewers"
+//	@Security		BearerAuth
+//	@Router			/tickets/{id}/presence [post]
+func HandleTicketPresenceAPI(c *gin.Context) {
+	ticketID, ok := lockTicketID(c)
+	if !ok {
+		return
+	}
+	var req presenceRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid presence request"})
+			return
+		}
+	}
+
+	queueID := 0
+	if v, ok := c.Get("queue_id"); ok {
+		if id, ok := v.(uint); ok {
+			queueID = int(id)
+		}
+	}
+	userID := GetUserIDFromCtx(c, 0)
+	viewers := events.DefaultPresence().Touch(ticketID, queueID, userID, presenceName(c, userID), req.Composing)
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": viewers})
+}
+
+// HandleLeaveTicketPresenceAPI handles DELETE /api/v1/tickets/:id/presence.
+//
+//	@Summary		Stop viewing a ticket
+//	@Tags			Tickets
+//	@Produce		json
+//	@Param			id	path		int	true	"Ticket ID"
+//	@Success		200	{object}	map[string]interface{}	"Left"
+//	@Security		BearerAuth
+//	@Router			/tickets/{id}/presence [delete]
+func HandleLeaveTicketPresenceAPI(c *gin.Context) {
+	ticketID, ok := lockTicketID(c)
+	if !ok {
+		return
+	}
+	events.DefaultPresence().Leave(ticketID, GetUserIDFromCtx(c, 0))
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// presenceName returns the agent's display name, falling back to the login
+// from the token.
+func presenceName(c *gin.Context, userID int) string {
+	if db, err := database.GetDB(); err == nil && db != nil {
+		var login, first, last string
+		err := db.QueryRowContext(c.Request.Context(), database.ConvertPlaceholders(
+			`SELECT login, first_name, last_name FROM users WHERE id = ?`), userID).Scan(&login, &first, &last)
+		if err == nil {
+			if name := strings.TrimSpace(first + " " + last); name != "" {
+				return name
+			}
+			return login
+		}
+	}
+	if name := c.GetString("username"); name != "" {
+		return name
+	}
+	return c.GetString("user_email")
+}
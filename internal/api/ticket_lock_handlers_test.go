@@ -0,0 +1,52 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/goatkit/goatflow/internal/service"
+)
+
+func TestTicketLockWriteError(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cases := []struct {
+		err  error
+		want int
+	}{
+		{service.ErrLockTicketNotFound, http.StatusNotFound},
+		{service.ErrTicketLockedByOther, http.StatusConflict},
+		{service.ErrTicketNotLocked, http.StatusConflict},
+		{service.ErrLockTakeoverDenied, http.StatusForbidden},
+		{errors.New("boom"), http.StatusInternalServerError},
+	}
+	for _, tc := range cases {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		ticketLockWriteError(c, tc.err, "lock ticket")
+		assert.Equal(t, tc.want, w.Code, tc.err.Error())
+	}
+}
+
+func TestLockTicketID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Params = gin.Params{{Key: "id", Value: "2024010100001"}}
+	c.Set("ticket_id", uint64(42))
+	id, ok := lockTicketID(c)
+	assert.True(t, ok)
+	assert.Equal(t, 42, id, "prefers the ID resolved by access middleware")
+
+	w = httptest.NewRecorder()
+	c, _ = gin.CreateTestContext(w)
+	c.Params = gin.Params{{Key: "id", Value: "abc"}}
+	_, ok = lockTicketID(c)
+	assert.False(t, ok)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,562 @@
+package api
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/history"
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/repository"
+	"github.com/goatkit/goatflow/internal/services"
+)
+
+// ticketMergeAPIRequest is the JSON body accepted by HandleMergeTicketAPI.
+type ticketMergeAPIRequest struct {
+	TargetTicketID int    `json:"target_ticket_id"`
+	TargetTN       string `json:"target_tn"`
+	Reason         string `json:"reason"`
+}
+
+// ticketSplitAPIRequest is the JSON body accepted by HandleSplitTicketAPI.
+type ticketSplitAPIRequest struct {
+	ArticleIDs []int  `json:"article_ids" binding:"required,min=1"`
+	Title      string `json:"title"`
+	QueueID    int    `json:"queue_id"`
+	Link       *bool  `json:"link"`
+}
+
+// HandleMergeTicketAPI merges the ticket in the path into a target ticket.
+// Articles, links and watchers are moved to the target, the source is set to
+// the merged state and a parent/child link records the cross-reference.
+//
+//	@Summary		Merge ticket
+//	@Description	Merge a ticket into a target ticket (articles, links and watchers are moved)
+//	@Tags			Ticket Actions
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int		true	"Source ticket ID"
+//	@Param			merge	body		object	true	"Merge data (target_ticket_id or target_tn, reason)"
+//	@Success		200		{object}	map[string]interface{}	"Ticket merged"
+//	@Failure		400		{object}	map[string]interface{}	"Invalid request"
+//	@Failure		403		{object}	map[string]interface{}	"No write access to target ticket"
+//	@Failure		404		{object}	map[string]interface{}	"Ticket not found"
+//	@Failure		409		{object}	map[string]interface{}	"Ticket already merged"
+//	@Security		BearerAuth
+//	@Router			/tickets/{id}/merge [post]
+func HandleMergeTicketAPI(c *gin.Context) {
+	sourceID, err := strconv.Atoi(c.Param("id"))
+	if err != nil || sourceID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid ticket ID"})
+		return
+	}
+
+	var req ticketMergeAPIRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid merge request: " + err.Error()})
+		return
+	}
+	req.TargetTN = strings.TrimSpace(req.TargetTN)
+	if req.TargetTicketID <= 0 && req.TargetTN == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "target_ticket_id or target_tn is required"})
+		return
+	}
+
+	db, err := database.GetDB()
+	if err != nil || db == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"success": false, "error": "Database unavailable"})
+		return
+	}
+
+	userID := GetUserIDFromCtx(c, 1)
+	ticketRepo := repository.NewTicketRepository(db)
+
+	source, err := ticketRepo.GetByID(uint(sourceID))
+	if err != nil || source == nil {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Ticket not found"})
+		return
+	}
+
+	var target *models.Ticket
+	if req.TargetTicketID > 0 {
+		target, err = ticketRepo.GetByID(uint(req.TargetTicketID))
+	} else {
+		target, err = ticketRepo.GetByTN(req.TargetTN)
+	}
+	if err != nil || target == nil {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Target ticket not found"})
+		return
+	}
+
+	if target.ID == source.ID {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Cannot merge ticket with itself"})
+		return
+	}
+
+	mergedStateID, err := lookupTicketStateIDByName(db, "merged")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Merged state is not configured"})
+		return
+	}
+	if source.TicketStateID == mergedStateID {
+		c.JSON(http.StatusConflict, gin.H{"success": false, "error": "Ticket is already merged"})
+		return
+	}
+	if target.TicketStateID == mergedStateID {
+		c.JSON(http.StatusConflict, gin.H{"success": false, "error": "Target ticket is already merged"})
+		return
+	}
+
+	// The route middleware only checked the source ticket's queue; the target
+	// receives articles so it needs rw as well.
+	if isAdmin, _ := c.Get("is_queue_admin"); isAdmin != true {
+		permSvc := services.NewPermissionService(db)
+		canWrite, err := permSvc.CanWriteQueue(userID, target.QueueID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to check permissions"})
+			return
+		}
+		if !canWrite {
+			c.JSON(http.StatusForbidden, gin.H{
+				"success": false,
+				"error":   "Write access denied - requires 'rw' permission on target queue",
+			})
+			return
+		}
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to start transaction"})
+		return
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	res, err := tx.Exec(database.ConvertPlaceholders(`
+		UPDATE article
+		SET ticket_id = ?, change_time = CURRENT_TIMESTAMP, change_by = ?
+		WHERE ticket_id = ?
+	`), target.ID, userID, source.ID)
+	if err != nil {
+		log.Printf("merge api: moving articles %d -> %d failed: %v", source.ID, target.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to move articles"})
+		return
+	}
+	articlesMoved, _ := res.RowsAffected() //nolint:errcheck // Informational only
+
+	linksMoved, err := moveTicketLinks(tx, source.ID, target.ID)
+	if err != nil {
+		log.Printf("merge api: moving links %d -> %d failed: %v", source.ID, target.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to move links"})
+		return
+	}
+
+	watchersAdded, err := moveTicketWatchers(tx, source.ID, target.ID, userID)
+	if err != nil {
+		log.Printf("merge api: moving watchers %d -> %d failed: %v", source.ID, target.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to move watchers"})
+		return
+	}
+
+	if _, err := tx.Exec(database.ConvertPlaceholders(`
+		UPDATE ticket
+		SET ticket_state_id = ?, change_time = CURRENT_TIMESTAMP, change_by = ?
+		WHERE id = ?
+	`), mergedStateID, userID, source.ID); err != nil {
+		log.Printf("merge api: closing source ticket %d failed: %v", source.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to close source ticket"})
+		return
+	}
+
+	if err := insertTicketLink(tx, target.ID, source.ID, "ParentChild", userID); err != nil {
+		log.Printf("merge api: linking %d -> %d failed: %v", target.ID, source.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to link tickets"})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to complete merge"})
+		return
+	}
+
+	recorder := history.NewRecorder(ticketRepo)
+	reasonSuffix := ""
+	if r := strings.TrimSpace(req.Reason); r != "" {
+		reasonSuffix = " Reason: " + r
+	}
+	_ = recorder.Record(c.Request.Context(), nil, source.ID, nil, history.TypeMerged, //nolint:errcheck // Best effort
+		fmt.Sprintf("Merged into ticket #%s.%s", target.TicketNumber, reasonSuffix), userID)
+	_ = recorder.Record(c.Request.Context(), nil, target.ID, nil, history.TypeMerged, //nolint:errcheck // Best effort
+		fmt.Sprintf("Merged ticket #%s into this ticket.%s", source.TicketNumber, reasonSuffix), userID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":          true,
+		"source_ticket_id": source.ID,
+		"source_tn":        source.TicketNumber,
+		"target_ticket_id": target.ID,
+		"target_tn":        target.TicketNumber,
+		"articles_moved":   articlesMoved,
+		"links_moved":      linksMoved,
+		"watchers_added":   watchersAdded,
+	})
+}
+
+// HandleSplitTicketAPI moves selected articles of the ticket in the path into
+// a newly created ticket that inherits the customer and priority of the source.
+//
+//	@Summary		Split ticket
+//	@Description	Move selected articles into a new ticket
+//	@Tags			Ticket Actions
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int		true	"Source ticket ID"
+//	@Param			split	body		object	true	"Split data (article_ids, title, queue_id, link)"
+//	@Success		201		{object}	map[string]interface{}	"Ticket split"
+//	@Failure		400		{object}	map[string]interface{}	"Invalid request"
+//	@Failure		403		{object}	map[string]interface{}	"No create access to target queue"
+//	@Failure		404		{object}	map[string]interface{}	"Ticket not found"
+//	@Security		BearerAuth
+//	@Router			/tickets/{id}/split [post]
+func HandleSplitTicketAPI(c *gin.Context) {
+	sourceID, err := strconv.Atoi(c.Param("id"))
+	if err != nil || sourceID <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid ticket ID"})
+		return
+	}
+
+	var req ticketSplitAPIRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid split request: " + err.Error()})
+		return
+	}
+
+	db, err := database.GetDB()
+	if err != nil || db == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"success": false, "error": "Database unavailable"})
+		return
+	}
+
+	userID := GetUserIDFromCtx(c, 1)
+	ticketRepo := repository.NewTicketRepository(db)
+
+	source, err := ticketRepo.GetByID(uint(sourceID))
+	if err != nil || source == nil {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Ticket not found"})
+		return
+	}
+
+	if req.QueueID <= 0 {
+		req.QueueID = source.QueueID
+	}
+	if strings.TrimSpace(req.Title) == "" {
+		req.Title = source.Title
+	}
+
+	if isAdmin, _ := c.Get("is_queue_admin"); isAdmin != true {
+		permSvc := services.NewPermissionService(db)
+		canCreate, err := permSvc.CanCreate(userID, req.QueueID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to check permissions"})
+			return
+		}
+		if !canCreate {
+			c.JSON(http.StatusForbidden, gin.H{"success": false, "error": "No permission to create tickets in this queue"})
+			return
+		}
+	}
+
+	articleIDs := uniqueInts(req.ArticleIDs)
+	if len(articleIDs) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "article_ids must contain valid article IDs"})
+		return
+	}
+	owned, total, err := countTicketArticles(db, source.ID, articleIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to load articles"})
+		return
+	}
+	if owned != len(articleIDs) {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "All article_ids must belong to the ticket"})
+		return
+	}
+	if owned == total {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "At least one article must remain on the source ticket"})
+		return
+	}
+
+	newStateID, err := lookupTicketStateIDByName(db, "new")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "New state is not configured"})
+		return
+	}
+
+	owner := userID
+	newTicket := &models.Ticket{
+		Title:            req.Title,
+		QueueID:          req.QueueID,
+		TicketLockID:     1,
+		TypeID:           source.TypeID,
+		ServiceID:        source.ServiceID,
+		SLAID:            source.SLAID,
+		UserID:           &owner,
+		CustomerID:       source.CustomerID,
+		CustomerUserID:   source.CustomerUserID,
+		TicketStateID:    newStateID,
+		TicketPriorityID: source.TicketPriorityID,
+		CreateBy:         userID,
+		ChangeBy:         userID,
+	}
+	if err := ticketRepo.Create(newTicket); err != nil {
+		log.Printf("split api: creating ticket from %d failed: %v", source.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to create ticket"})
+		return
+	}
+
+	if err := moveArticlesForSplit(db, source.ID, newTicket.ID, articleIDs, userID, req.Link == nil || *req.Link); err != nil {
+		log.Printf("split api: moving articles %d -> %d failed: %v", source.ID, newTicket.ID, err)
+		if delErr := ticketRepo.Delete(uint(newTicket.ID)); delErr != nil {
+			log.Printf("split api: cleanup of ticket %d failed: %v", newTicket.ID, delErr)
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to move articles"})
+		return
+	}
+
+	recorder := history.NewRecorder(ticketRepo)
+	_ = recorder.Record(c.Request.Context(), nil, newTicket, nil, history.TypeNewTicket, //nolint:errcheck // Best effort
+		fmt.Sprintf("Split from ticket #%s.", source.TicketNumber), userID)
+	_ = recorder.Record(c.Request.Context(), nil, source, nil, history.TypeMisc, //nolint:errcheck // Best effort
+		fmt.Sprintf("Split %d article(s) into ticket #%s.", len(articleIDs), newTicket.TicketNumber), userID)
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success":          true,
+		"source_ticket_id": source.ID,
+		"ticket_id":        newTicket.ID,
+		"tn":               newTicket.TicketNumber,
+		"queue_id":         newTicket.QueueID,
+		"articles_moved":   len(articleIDs),
+	})
+}
+
+// moveArticlesForSplit moves the given articles into the new ticket and
+// optionally links both tickets, all in one transaction.
+func moveArticlesForSplit(db *sql.DB, sourceID, newID int, articleIDs []int, userID int, link bool) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	placeholders, args := intInClause(articleIDs)
+	query := fmt.Sprintf(`
+		UPDATE article
+		SET ticket_id = ?, change_time = CURRENT_TIMESTAMP, change_by = ?
+		WHERE ticket_id = ? AND id IN (%s)
+	`, placeholders)
+	args = append([]interface{}{newID, userID, sourceID}, args...)
+	if _, err := tx.Exec(database.ConvertPlaceholders(query), args...); err != nil {
+		return err
+	}
+
+	if link {
+		if err := insertTicketLink(tx, sourceID, newID, "ParentChild", userID); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// countTicketArticles returns how many of articleIDs belong to the ticket and
+// the ticket's total article count.
+func countTicketArticles(db *sql.DB, ticketID int, articleIDs []int) (owned int, total int, err error) {
+	placeholders, args := intInClause(articleIDs)
+	query := fmt.Sprintf(`SELECT COUNT(*) FROM article WHERE ticket_id = ? AND id IN (%s)`, placeholders)
+	args = append([]interface{}{ticketID}, args...)
+	if err = db.QueryRow(database.ConvertPlaceholders(query), args...).Scan(&owned); err != nil {
+		return 0, 0, err
+	}
+	err = db.QueryRow(database.ConvertPlaceholders(
+		`SELECT COUNT(*) FROM article WHERE ticket_id = ?`,
+	), ticketID).Scan(&total)
+	return owned, total, err
+}
+
+// moveTicketLinks re-points every ticket link of sourceID to targetID.
+// Links that would become self-links or duplicates are dropped.
+func moveTicketLinks(tx *sql.Tx, sourceID, targetID int) (int, error) {
+	srcKey := strconv.Itoa(sourceID)
+	dstKey := strconv.Itoa(targetID)
+
+	rows, err := tx.Query(database.ConvertPlaceholders(`
+		SELECT lr.source_key, lr.target_key, lr.type_id, lr.state_id, lr.create_time, lr.create_by
+		FROM link_relation lr
+		JOIN link_object src_obj ON src_obj.id = lr.source_object_id
+		JOIN link_object dst_obj ON dst_obj.id = lr.target_object_id
+		WHERE src_obj.name = 'Ticket' AND dst_obj.name = 'Ticket'
+		  AND (lr.source_key = ? OR lr.target_key = ?)
+	`), srcKey, srcKey)
+	if err != nil {
+		return 0, err
+	}
+
+	type linkRow struct {
+		sourceKey, targetKey string
+		typeID, stateID      int
+		createTime           interface{}
+		createBy             int
+	}
+	var links []linkRow
+	for rows.Next() {
+		var l linkRow
+		if err := rows.Scan(&l.sourceKey, &l.targetKey, &l.typeID, &l.stateID, &l.createTime, &l.createBy); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		links = append(links, l)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	moved := 0
+	for _, l := range links {
+		newSource, newTarget := l.sourceKey, l.targetKey
+		if newSource == srcKey {
+			newSource = dstKey
+		}
+		if newTarget == srcKey {
+			newTarget = dstKey
+		}
+		if newSource == newTarget {
+			continue
+		}
+
+		var exists int
+		err := tx.QueryRow(database.ConvertPlaceholders(`
+			SELECT COUNT(*) FROM link_relation
+			WHERE source_object_id = (SELECT id FROM link_object WHERE name = 'Ticket')
+			  AND target_object_id = (SELECT id FROM link_object WHERE name = 'Ticket')
+			  AND source_key = ? AND target_key = ? AND type_id = ?
+		`), newSource, newTarget, l.typeID).Scan(&exists)
+		if err != nil {
+			return moved, err
+		}
+		if exists > 0 {
+			continue
+		}
+
+		if _, err := tx.Exec(database.ConvertPlaceholders(`
+			INSERT INTO link_relation
+				(source_object_id, source_key, target_object_id, target_key, type_id, state_id, create_time, create_by)
+			VALUES
+				((SELECT id FROM link_object WHERE name = 'Ticket'), ?,
+				 (SELECT id FROM link_object WHERE name = 'Ticket'), ?,
+				 ?, ?, ?, ?)
+		`), newSource, newTarget, l.typeID, l.stateID, l.createTime, l.createBy); err != nil {
+			return moved, err
+		}
+		moved++
+	}
+
+	_, err = tx.Exec(database.ConvertPlaceholders(`
+		DELETE FROM link_relation
+		WHERE source_object_id = (SELECT id FROM link_object WHERE name = 'Ticket')
+		  AND target_object_id = (SELECT id FROM link_object WHERE name = 'Ticket')
+		  AND (source_key = ? OR target_key = ?)
+	`), srcKey, srcKey)
+	return moved, err
+}
+
+// moveTicketWatchers subscribes every watcher of sourceID to targetID (unless
+// already watching) and removes the subscriptions from the source.
+func moveTicketWatchers(tx *sql.Tx, sourceID, targetID, userID int) (int, error) {
+	rows, err := tx.Query(database.ConvertPlaceholders(`
+		SELECT user_id FROM ticket_watcher
+		WHERE ticket_id = ?
+		  AND user_id NOT IN (SELECT user_id FROM ticket_watcher WHERE ticket_id = ?)
+	`), sourceID, targetID)
+	if err != nil {
+		return 0, err
+	}
+	var watcherIDs []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		watcherIDs = append(watcherIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, watcherID := range watcherIDs {
+		if _, err := tx.Exec(database.ConvertPlaceholders(`
+			INSERT INTO ticket_watcher (ticket_id, user_id, create_time, create_by, change_time, change_by)
+			VALUES (?, ?, CURRENT_TIMESTAMP, ?, CURRENT_TIMESTAMP, ?)
+		`), targetID, watcherID, userID, userID); err != nil {
+			return 0, err
+		}
+	}
+
+	_, err = tx.Exec(database.ConvertPlaceholders(`DELETE FROM ticket_watcher WHERE ticket_id = ?`), sourceID)
+	return len(watcherIDs), err
+}
+
+// insertTicketLink creates a valid ticket-to-ticket link of the given type.
+func insertTicketLink(tx *sql.Tx, sourceID, targetID int, linkType string, userID int) error {
+	_, err := tx.Exec(database.ConvertPlaceholders(`
+		INSERT INTO link_relation
+			(source_object_id, source_key, target_object_id, target_key, type_id, state_id, create_time, create_by)
+		VALUES
+			((SELECT id FROM link_object WHERE name = 'Ticket'), ?,
+			 (SELECT id FROM link_object WHERE name = 'Ticket'), ?,
+			 (SELECT id FROM link_type WHERE name = ?),
+			 (SELECT id FROM link_state WHERE name = 'Valid'),
+			 CURRENT_TIMESTAMP, ?)
+	`), strconv.Itoa(sourceID), strconv.Itoa(targetID), linkType, userID)
+	return err
+}
+
+// lookupTicketStateIDByName resolves a ticket state name to its ID.
+func lookupTicketStateIDByName(db *sql.DB, name string) (int, error) {
+	var id int
+	err := db.QueryRow(database.ConvertPlaceholders(
+		`SELECT id FROM ticket_state WHERE name = ?`,
+	), name).Scan(&id)
+	return id, err
+}
+
+// intInClause builds "?,?,?" and the matching argument slice for ids.
+func intInClause(ids []int) (string, []interface{}) {
+	placeholders := make([]string, len(ids))
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		placeholders[i] = "?"
+		args[i] = id
+	}
+	return strings.Join(placeholders, ","), args
+}
+
+// uniqueInts returns ids without duplicates or non-positive values, preserving order.
+func uniqueInts(ids []int) []int {
+	seen := make(map[int]bool, len(ids))
+	out := make([]int, 0, len(ids))
+	for _, id := range ids {
+		if id <= 0 || seen[id] {
+			continue
+		}
+		seen[id] = true
+		out = append(out, id)
+	}
+	return out
+}
@@ -0,0 +1,106 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHandleMergeTicketAPI_Validation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name      string
+		ticketID  string
+		body      string
+		wantError string
+	}{
+		{
+			name:      "invalid ticket id",
+			ticketID:  "abc",
+			body:      `{"target_ticket_id": 2}`,
+			wantError: "Invalid ticket ID",
+		},
+		{
+			name:      "missing target",
+			ticketID:  "1",
+			body:      `{"reason": "duplicate"}`,
+			wantError: "target_ticket_id or target_tn is required",
+		},
+		{
+			name:      "malformed json",
+			ticketID:  "1",
+			body:      `{"target_ticket_id":`,
+			wantError: "Invalid merge request",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.POST("/api/v1/tickets/:id/merge", HandleMergeTicketAPI)
+
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/tickets/"+tt.ticketID+"/merge", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Contains(t, w.Body.String(), tt.wantError)
+		})
+	}
+}
+
+func TestHandleSplitTicketAPI_Validation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name      string
+		ticketID  string
+		body      string
+		wantError string
+	}{
+		{
+			name:      "invalid ticket id",
+			ticketID:  "0",
+			body:      `{"article_ids": [1]}`,
+			wantError: "Invalid ticket ID",
+		},
+		{
+			name:      "no articles",
+			ticketID:  "1",
+			body:      `{"article_ids": []}`,
+			wantError: "Invalid split request",
+		},
+		{
+			name:      "articles missing",
+			ticketID:  "1",
+			body:      `{"title": "Split"}`,
+			wantError: "Invalid split request",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.POST("/api/v1/tickets/:id/split", HandleSplitTicketAPI)
+
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/tickets/"+tt.ticketID+"/split", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Contains(t, w.Body.String(), tt.wantError)
+		})
+	}
+}
+
+func TestUniqueInts(t *testing.T) {
+	assert.Equal(t, []int{3, 1, 2}, uniqueInts([]int{3, 1, 3, 0, -4, 2, 1}))
+	assert.Empty(t, uniqueInts(nil))
+}
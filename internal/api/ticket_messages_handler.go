@@ -13,6 +13,7 @@ import (
 
 	"github.com/goatkit/goatflow/internal/database"
 	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/preview"
 	"github.com/goatkit/goatflow/internal/repository"
 	"github.com/goatkit/goatflow/internal/service"
 )
@@ -284,11 +285,16 @@ func renderSimpleMessageHTML(msg *service.SimpleTicketMessage, ticketID uint) st
 			// Format file size
 			sizeStr := formatFileSize(att.Size)
 			// Use thumbnail for images, icon for others
-			var thumbnailHTML string
-			if service.IsSupportedImageType(att.ContentType) {
-				// Extract attachment ID from URL and use correct thumbnail endpoint
-				attachmentID := extractAttachmentID(att.URL)
-				thumbnailURL := fmt.Sprintf("/api/tickets/%d/attachments/%s/thumbnail", ticketID, attachmentID)
+			var thumbnailHTML, thumbnailURL string
+			// Extract attachment ID from URL and use correct thumbnail endpoint;
+			// cached previews also cover PDFs
+			attachmentID := extractAttachmentID(att.URL)
+			if previews := preview.Default(); previews != nil && previews.Supports(att.ContentType) {
+				thumbnailURL = fmt.Sprintf("/api/v1/attachments/%s/preview", attachmentID)
+			} else if service.IsSupportedImageType(att.ContentType) {
+				thumbnailURL = fmt.Sprintf("/api/tickets/%d/attachments/%s/thumbnail", ticketID, attachmentID)
+			}
+			if thumbnailURL != "" {
 				thumbnailHTML = fmt.Sprintf(`
 					<div class="w-16 h-16 rounded-lg overflow-hidden bg-gray-200 dark:bg-gray-700 flex-shrink-0 cursor-pointer hover:ring-2 hover:ring-blue-500 transition-all"
 					     onclick="previewAttachment('%s', '%s', '%s')"
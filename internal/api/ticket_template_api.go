@@ -0,0 +1,331 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/repository"
+	"github.com/goatkit/goatflow/internal/service"
+)
+
+// ticketTemplateRequest is the JSON body accepted by the ticket template
+// create/update handlers.
+type ticketTemplateRequest struct {
+	Name          string            `json:"name" binding:"required"`
+	Description   string            `json:"description"`
+	Category      string            `json:"category"`
+	Subject       string            `json:"subject" binding:"required"`
+	Body          string            `json:"body" binding:"required"`
+	ContentType   string            `json:"content_type"`
+	QueueID       int               `json:"queue_id"`
+	PriorityID    int               `json:"priority_id"`
+	TypeID        int               `json:"type_id"`
+	StateID       int               `json:"state_id"`
+	ServiceID     int               `json:"service_id"`
+	DynamicFields map[string]string `json:"dynamic_fields"`
+	Tags          []string          `json:"tags"`
+	GroupIDs      []int             `json:"group_ids"`
+	Active        *bool             `json:"active"`
+}
+
+func (r *ticketTemplateRequest) template() *models.TicketTemplate {
+	active := r.Active == nil || *r.Active
+	return &models.TicketTemplate{
+		Name:          r.Name,
+		Description:   r.Description,
+		Category:      r.Category,
+		Subject:       r.Subject,
+		Body:          r.Body,
+		ContentType:   r.ContentType,
+		QueueID:       r.QueueID,
+		PriorityID:    r.PriorityID,
+		TypeID:        r.TypeID,
+		StateID:       r.StateID,
+		ServiceID:     r.ServiceID,
+		DynamicFields: r.DynamicFields,
+		Tags:          r.Tags,
+		GroupIDs:      r.GroupIDs,
+		Active:        active,
+	}
+}
+
+// ticketTemplateService returns the service, writing 503 when the database
+// is unavailable.
+func ticketTemplateService(c *gin.Context) *service.TicketTemplateService {
+	db, err := database.GetDB()
+	if err != nil || db == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"success": false, "error": "Database unavailable"})
+		return nil
+	}
+	return service.NewTicketTemplateService(repository.NewTicketTemplateSQLRepository(db), nil)
+}
+
+// ticketTemplateError maps TicketTemplateService errors to responses.
+func ticketTemplateError(c *gin.Context, err error, action string) {
+	switch {
+	case errors.Is(err, service.ErrTicketTemplateNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Ticket template not found"})
+	case errors.Is(err, service.ErrTicketTemplateNameExists):
+		c.JSON(http.StatusConflict, gin.H{"success": false, "error": err.Error()})
+	case errors.Is(err, service.ErrTicketTemplateInvalid):
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+	default:
+		log.Printf("ticket template api: %s failed: %v", action, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to " + action})
+	}
+}
+
+// ticketTemplateID parses the :id path parameter, writing 400 when it is
+// invalid.
+func ticketTemplateID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil || id == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid ticket template ID"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// visibleTicketTemplates returns the templates the current agent may use,
+// optionally of one category.
+func visibleTicketTemplates(c *gin.Context, svc *service.TicketTemplateService) ([]models.TicketTemplate, error) {
+	list, err := svc.GetTemplatesForUser(c.Request.Context(), GetUserIDFromCtx(c, 1))
+	if err != nil {
+		return nil, err
+	}
+	category := c.Query("category")
+	templates := make([]models.TicketTemplate, 0, len(list))
+	for _, t := range list {
+		if category == "" || t.Category == category {
+			templates = append(templates, t)
+		}
+	}
+	return templates, nil
+}
+
+// HandleListTicketTemplatesAPI handles GET /api/v1/ticket-templates.
+//
+//	@Summary		List ticket templates
+//	@Description	Active templates the agent may use: those without groups and those of the agent's groups.
+//	@Tags			Ticket Templates
+//	@Produce		json
+//	@Param			category	query		string	false	"Only templates of this category"
+//	@Success		200			{object}	map[string]interface{}	"Ticket templates"
+//	@Security		BearerAuth
+//	@Router			/ticket-templates [get]
+func HandleListTicketTemplatesAPI(c *gin.Context) {
+	svc := ticketTemplateService(c)
+	if svc == nil {
+		return
+	}
+	templates, err := visibleTicketTemplates(c, svc)
+	if err != nil {
+		ticketTemplateError(c, err, "load ticket templates")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": templates})
+}
+
+// HandleGetTicketTemplateAPI handles GET /api/v1/ticket-templates/:id.
+//
+//	@Summary		Get ticket template
+//	@Tags			Ticket Templates
+//	@Produce		json
+//	@Param			id	path		int	true	"Ticket template ID"
+//	@Success		200	{object}	map[string]interface{}	"Ticket template"
+//	@Failure		404	{object}	map[string]interface{}	"Not found or not available to the agent"
+//	@Security		BearerAuth
+//	@Router			/ticket-templates/{id} [get]
+func HandleGetTicketTemplateAPI(c *gin.Context) {
+	id, ok := ticketTemplateID(c)
+	if !ok {
+		return
+	}
+	svc := ticketTemplateService(c)
+	if svc == nil {
+		return
+	}
+	templates, err := visibleTicketTemplates(c, svc)
+	if err != nil {
+		ticketTemplateError(c, err, "load ticket template")
+		return
+	}
+	for _, t := range templates {
+		if t.ID == id {
+			c.JSON(http.StatusOK, gin.H{"success": true, "data": t})
+			return
+		}
+	}
+	ticketTemplateError(c, service.ErrTicketTemplateNotFound, "load ticket template")
+}
+
+// HandleAdminListTicketTemplatesAPI handles GET /api/v1/admin/ticket-templates.
+//
+//	@Summary		List all ticket templates
+//	@Tags			Ticket Templates
+//	@Produce		json
+//	@Success		200	{object}	map[string]interface{}	"Ticket templates, including inactive ones"
+//	@Security		BearerAuth
+//	@Router			/admin/ticket-templates [get]
+func HandleAdminListTicketTemplatesAPI(c *gin.Context) {
+	svc := ticketTemplateService(c)
+	if svc == nil {
+		return
+	}
+	list, err := svc.GetAllTemplates(c.Request.Context())
+	if err != nil {
+		ticketTemplateError(c, err, "load ticket templates")
+		return
+	}
+	if list == nil {
+		list = []models.TicketTemplate{}
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": list})
+}
+
+// HandleAdminGetTicketTemplateAPI handles GET /api/v1/admin/ticket-templates/:id.
+//
+//	@Summary		Get ticket template (admin)
+//	@Tags			Ticket Templates
+//	@Produce		json
+//	@Param			id	path		int	true	"Ticket template ID"
+//	@Success		200	{object}	map[string]interface{}	"Ticket template"
+//	@Failure		404	{object}	map[string]interface{}	"Ticket template not found"
+//	@Security		BearerAuth
+//	@Router			/admin/ticket-templates/{id} [get]
+func HandleAdminGetTicketTemplateAPI(c *gin.Context) {
+	id, ok := ticketTemplateID(c)
+	if !ok {
+		return
+	}
+	svc := ticketTemplateService(c)
+	if svc == nil {
+		return
+	}
+	template, err := svc.GetTemplate(c.Request.Context(), id)
+	if err != nil {
+		ticketTemplateError(c, err, "load ticket template")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": template})
+}
+
+// HandleCreateTicketTemplateAPI handles POST /api/v1/admin/ticket-templates.
+//
+//	@Summary		Create ticket template
+//	@Tags			Ticket Templates
+//	@Accept			json
+//	@Produce		json
+//	@Param			template	body		object	true	"Ticket template (name, subject, body, queue_id, priority_id, dynamic_fields, group_ids, ...)"
+//	@Success		201			{object}	map[string]interface{}	"Ticket template created"
+//	@Failure		400			{object}	map[string]interface{}	"Invalid request"
+//	@Failure		409			{object}	map[string]interface{}	"Name already in use"
+//	@Security		BearerAuth
+//	@Router			/admin/ticket-templates [post]
+func HandleCreateTicketTemplateAPI(c *gin.Context) {
+	var req ticketTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid ticket template request: " + err.Error()})
+		return
+	}
+	svc := ticketTemplateService(c)
+	if svc == nil {
+		return
+	}
+	template := req.template()
+	template.CreatedBy = GetUserIDFromCtx(c, 1)
+	if err := svc.CreateTemplate(c.Request.Context(), template); err != nil {
+		ticketTemplateError(c, err, "create ticket template")
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"success": true, "data": template})
+}
+
+// HandleUpdateTicketTemplateAPI handles PUT /api/v1/admin/ticket-templates/:id.
+//
+//	@Summary		Update ticket template
+//	@Description	Replaces the template, including its groups.
+//	@Tags			Ticket Templates
+//	@Accept			json
+//	@Produce		json
+//	@Param			id			path		int		true	"Ticket template ID"
+//	@Param			template	body		object	true	"Ticket template"
+//	@Success		200			{object}	map[string]interface{}	"Ticket template updated"
+//	@Failure		400			{object}	map[string]interface{}	"Invalid request"
+//	@Failure		404			{object}	map[string]interface{}	"Ticket template not found"
+//	@Failure		409			{object}	map[string]interface{}	"Name already in use"
+//	@Security		BearerAuth
+//	@Router			/admin/ticket-templates/{id} [put]
+func HandleUpdateTicketTemplateAPI(c *gin.Context) {
+	id, ok := ticketTemplateID(c)
+	if !ok {
+		return
+	}
+	var req ticketTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid ticket template request: " + err.Error()})
+		return
+	}
+	svc := ticketTemplateService(c)
+	if svc == nil {
+		return
+	}
+	template := req.template()
+	template.ID = id
+	template.UpdatedBy = GetUserIDFromCtx(c, 1)
+	if err := svc.UpdateTemplate(c.Request.Context(), template); err != nil {
+		ticketTemplateError(c, err, "update ticket template")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": template})
+}
+
+// HandleDeleteTicketTemplateAPI handles DELETE /api/v1/admin/ticket-templates/:id.
+//
+//	@Summary		Delete ticket template
+//	@Tags			Ticket Templates
+//	@Produce		json
+//	@Param			id	path		int	true	"Ticket template ID"
+//	@Success		200	{object}	map[string]interface{}	"Ticket template deleted"
+//	@Failure		404	{object}	map[string]interface{}	"Ticket template not found"
+//	@Security		BearerAuth
+//	@Router			/admin/ticket-templates/{id} [delete]
+func HandleDeleteTicketTemplateAPI(c *gin.Context) {
+	id, ok := ticketTemplateID(c)
+	if !ok {
+		return
+	}
+	svc := ticketTemplateService(c)
+	if svc == nil {
+		return
+	}
+	if err := svc.DeleteTemplate(c.Request.Context(), id); err != nil {
+		ticketTemplateError(c, err, "delete ticket template")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// recordTicketTemplateUsage counts a ticket created from the template named
+// by the ticket_template_id form field. Failures are logged only; the
+// ticket already exists.
+func recordTicketTemplateUsage(c *gin.Context) {
+	id, err := strconv.ParseUint(c.PostForm("ticket_template_id"), 10, 32)
+	if err != nil || id == 0 {
+		return
+	}
+	db, err := database.GetDB()
+	if err != nil || db == nil {
+		return
+	}
+	svc := service.NewTicketTemplateService(repository.NewTicketTemplateSQLRepository(db), nil)
+	if err := svc.RecordUsage(c.Request.Context(), uint(id)); err != nil {
+		log.Printf("ticket template api: record usage of template %d failed: %v", id, err)
+	}
+}
@@ -0,0 +1,48 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTicketTemplateHandlers_InvalidRequest(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.GET("/api/v1/ticket-templates/:id", HandleGetTicketTemplateAPI)
+	router.POST("/api/v1/admin/ticket-templates", HandleCreateTicketTemplateAPI)
+	router.GET("/api/v1/admin/ticket-templates/:id", HandleAdminGetTicketTemplateAPI)
+	router.PUT("/api/v1/admin/ticket-templates/:id", HandleUpdateTicketTemplateAPI)
+	router.DELETE("/api/v1/admin/ticket-templates/:id", HandleDeleteTicketTemplateAPI)
+
+	for _, tc := range []struct {
+		method string
+		path   string
+		body   string
+		want   string
+	}{
+		{http.MethodGet, "/api/v1/ticket-templates/abc", "", "Invalid ticket template ID"},
+		{http.MethodGet, "/api/v1/admin/ticket-templates/0", "", "Invalid ticket template ID"},
+		{http.MethodPut, "/api/v1/admin/ticket-templates/-1", `{}`, "Invalid ticket template ID"},
+		{http.MethodDelete, "/api/v1/admin/ticket-templates/x", "", "Invalid ticket template ID"},
+		{http.MethodPost, "/api/v1/admin/ticket-templates", `{"subject": "Refund", "body": "x"}`, "Invalid ticket template request"},
+		{http.MethodPost, "/api/v1/admin/ticket-templates", `{"name": "Refund", "body": "x"}`, "Invalid ticket template request"},
+		{http.MethodPost, "/api/v1/admin/ticket-templates", `{"name": `, "Invalid ticket template request"},
+		{http.MethodPut, "/api/v1/admin/ticket-templates/1", `{"name": "Refund", "subject": "Refund"}`, "Invalid ticket template request"},
+	} {
+		t.Run(tc.method+" "+tc.path+" "+tc.body, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Contains(t, w.Body.String(), tc.want)
+		})
+	}
+}
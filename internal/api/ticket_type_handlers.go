@@ -0,0 +1,283 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/service"
+)
+
+// ticketTypeRequest is the JSON body accepted by the type create/update handlers.
+type ticketTypeRequest struct {
+	Name    string `json:"name" binding:"required"`
+	ValidID int    `json:"valid_id"`
+}
+
+func ticketTypeService(c *gin.Context) *service.TicketTypeService {
+	db, err := database.GetDB()
+	if err != nil || db == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"success": false, "error": "Database unavailable"})
+		return nil
+	}
+	return service.NewTicketTypeService(db)
+}
+
+// ticketTypeID parses the :id path parameter, writing 400 when it is invalid.
+func ticketTypeID(c *gin.Context) (int, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid type ID"})
+		return 0, false
+	}
+	return id, true
+}
+
+// ticketTypeWriteError maps TicketTypeService errors to responses.
+func ticketTypeWriteError(c *gin.Context, err error, action string) {
+	switch {
+	case errors.Is(err, service.ErrTicketTypeNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Ticket type not found"})
+	case errors.Is(err, service.ErrTicketTypeTicketNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "Ticket not found"})
+	case errors.Is(err, service.ErrTicketTypeNameExists),
+		errors.Is(err, service.ErrTicketTypeInUse),
+		errors.Is(err, service.ErrTicketTypeTransitionNotAllowed):
+		c.JSON(http.StatusConflict, gin.H{"success": false, "error": err.Error()})
+	case errors.Is(err, service.ErrTicketTypeNameRequired),
+		errors.Is(err, service.ErrTicketTypeNameTooLong),
+		errors.Is(err, service.ErrTicketTypeInvalidDefault),
+		errors.Is(err, service.ErrTicketTypeInvalidField),
+		errors.Is(err, service.ErrTicketTypeInvalidState):
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+	default:
+		log.Printf("ticket type api: %s failed: %v", action, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to " + action})
+	}
+}
+
+// rejectTicketTypeTransition checks whether the ticket's type allows moving
+// it to stateID. When it does not, it writes 409 and returns true; the
+// caller must then leave the ticket unchanged.
+func rejectTicketTypeTransition(c *gin.Context, db *sql.DB, ticketID, stateID int) bool {
+	err := service.NewTicketTypeService(db).CheckTransition(c.Request.Context(), ticketID, stateID)
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, service.ErrTicketTypeTransitionNotAllowed),
+		errors.Is(err, service.ErrTicketTypeTicketNotFound):
+		ticketTypeWriteError(c, err, "check ticket type workflow")
+	default:
+		log.Printf("ticket type: checking state change of ticket %d failed: %v", ticketID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to check ticket type workflow"})
+	}
+	return true
+}
+
+// HandleGetTypeAPI handles GET /api/v1/types/:id.
+//
+//	@Summary		Get ticket type
+//	@Tags			Types
+//	@Produce		json
+//	@Param			id	path		int	true	"Type ID"
+//	@Success		200	{object}	map[string]interface{}	"Ticket type"
+//	@Failure		404	{object}	map[string]interface{}	"Type not found"
+//	@Security		BearerAuth
+//	@Router			/types/{id} [get]
+func HandleGetTypeAPI(c *gin.Context) {
+	id, ok := ticketTypeID(c)
+	if !ok {
+		return
+	}
+	svc := ticketTypeService(c)
+	if svc == nil {
+		return
+	}
+	tt, err := svc.Get(c.Request.Context(), id)
+	if err != nil {
+		ticketTypeWriteError(c, err, "load ticket type")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": tt})
+}
+
+// HandleCreateTypeAPI handles POST /api/v1/types.
+//
+//	@Summary		Create ticket type
+//	@Tags			Types
+//	@Accept			json
+//	@Produce		json
+//	@Param			type	body		object	true	"Ticket type (name, valid_id)"
+//	@Success		201		{object}	map[string]interface{}	"Type created"
+//	@Failure		400		{object}	map[string]interface{}	"Invalid request"
+//	@Failure		409		{object}	map[string]interface{}	"Name already in use"
+//	@Security		BearerAuth
+//	@Router			/types [post]
+func HandleCreateTypeAPI(c *gin.Context) {
+	var req ticketTypeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid ticket type: " + err.Error()})
+		return
+	}
+	svc := ticketTypeService(c)
+	if svc == nil {
+		return
+	}
+
+	tt := &models.TicketType{Name: req.Name, ValidID: req.ValidID, CreateBy: GetUserIDFromCtx(c, 1)}
+	if err := svc.Create(c.Request.Context(), tt); err != nil {
+		ticketTypeWriteError(c, err, "create ticket type")
+		return
+	}
+	created, err := svc.Get(c.Request.Context(), tt.ID)
+	if err != nil {
+		ticketTypeWriteError(c, err, "load ticket type")
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"success": true, "data": created})
+}
+
+// HandleUpdateTypeAPI handles PUT /api/v1/types/:id.
+//
+//	@Summary		Update ticket type
+//	@Tags			Types
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int		true	"Type ID"
+//	@Param			type	body		object	true	"Ticket type (name, valid_id)"
+//	@Success		200		{object}	map[string]interface{}	"Type updated"
+//	@Failure		400		{object}	map[string]interface{}	"Invalid request"
+//	@Failure		404		{object}	map[string]interface{}	"Type not found"
+//	@Security		BearerAuth
+//	@Router			/types/{id} [put]
+func HandleUpdateTypeAPI(c *gin.Context) {
+	id, ok := ticketTypeID(c)
+	if !ok {
+		return
+	}
+	var req ticketTypeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid ticket type: " + err.Error()})
+		return
+	}
+	svc := ticketTypeService(c)
+	if svc == nil {
+		return
+	}
+
+	tt := &models.TicketType{ID: id, Name: req.Name, ValidID: req.ValidID}
+	if err := svc.Update(c.Request.Context(), tt, GetUserIDFromCtx(c, 1)); err != nil {
+		ticketTypeWriteError(c, err, "update ticket type")
+		return
+	}
+	updated, err := svc.Get(c.Request.Context(), id)
+	if err != nil {
+		ticketTypeWriteError(c, err, "load ticket type")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": updated})
+}
+
+// HandleDeleteTypeAPI handles DELETE /api/v1/types/:id.
+//
+//	@Summary		Delete ticket type
+//	@Description	Invalidates the type. Types still used by tickets cannot be deleted.
+//	@Tags			Types
+//	@Produce		json
+//	@Param			id	path		int	true	"Type ID"
+//	@Success		200	{object}	map[string]interface{}	"Type deleted"
+//	@Failure		404	{object}	map[string]interface{}	"Type not found"
+//	@Failure		409	{object}	map[string]interface{}	"Type in use"
+//	@Security		BearerAuth
+//	@Router			/types/{id} [delete]
+func HandleDeleteTypeAPI(c *gin.Context) {
+	id, ok := ticketTypeID(c)
+	if !ok {
+		return
+	}
+	svc := ticketTypeService(c)
+	if svc == nil {
+		return
+	}
+	if err := svc.Delete(c.Request.Context(), id, GetUserIDFromCtx(c, 1)); err != nil {
+		ticketTypeWriteError(c, err, "delete ticket type")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Ticket type deleted"})
+}
+
+// HandleGetTypeWorkflowAPI handles GET /api/v1/types/:id/workflow.
+//
+//	@Summary		Get ticket type workflow
+//	@Description	Returns the type's default queue, priority and SLA, its dynamic field set and its allowed state transitions. Empty field and transition lists mean no restriction.
+//	@Tags			Types
+//	@Produce		json
+//	@Param			id	path		int	true	"Type ID"
+//	@Success		200	{object}	map[string]interface{}	"Workflow"
+//	@Failure		404	{object}	map[string]interface{}	"Type not found"
+//	@Security		BearerAuth
+//	@Router			/types/{id}/workflow [get]
+func HandleGetTypeWorkflowAPI(c *gin.Context) {
+	id, ok := ticketTypeID(c)
+	if !ok {
+		return
+	}
+	svc := ticketTypeService(c)
+	if svc == nil {
+		return
+	}
+	wf, err := svc.GetWorkflow(c.Request.Context(), id)
+	if err != nil {
+		ticketTypeWriteError(c, err, "load ticket type workflow")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": wf})
+}
+
+// HandleUpdateTypeWorkflowAPI handles PUT /api/v1/types/:id/workflow.
+//
+//	@Summary		Replace ticket type workflow
+//	@Description	Replaces the type's defaults, dynamic field set and allowed state transitions. Tickets of the type can then only change state along the listed transitions.
+//	@Tags			Types
+//	@Accept			json
+//	@Produce		json
+//	@Param			id			path		int		true	"Type ID"
+//	@Param			workflow	body		object	true	"Workflow (default_queue_id, default_priority_id, default_sla_id, dynamic_fields, transitions)"
+//	@Success		200			{object}	map[string]interface{}	"Workflow saved"
+//	@Failure		400			{object}	map[string]interface{}	"Invalid request"
+//	@Failure		404			{object}	map[string]interface{}	"Type not found"
+//	@Security		BearerAuth
+//	@Router			/types/{id}/workflow [put]
+func HandleUpdateTypeWorkflowAPI(c *gin.Context) {
+	id, ok := ticketTypeID(c)
+	if !ok {
+		return
+	}
+	var wf models.TicketTypeWorkflow
+	if err := c.ShouldBindJSON(&wf); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid workflow: " + err.Error()})
+		return
+	}
+	wf.TypeID = id
+	svc := ticketTypeService(c)
+	if svc == nil {
+		return
+	}
+
+	if err := svc.SaveWorkflow(c.Request.Context(), &wf, GetUserIDFromCtx(c, 1)); err != nil {
+		ticketTypeWriteError(c, err, "save ticket type workflow")
+		return
+	}
+	saved, err := svc.GetWorkflow(c.Request.Context(), id)
+	if err != nil {
+		ticketTypeWriteError(c, err, "load ticket type workflow")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": saved})
+}
@@ -3,6 +3,7 @@ package api
 import (
 	"database/sql"
 	"fmt"
+	"log"
 	"net/http"
 	"os"
 	"strconv"
@@ -12,6 +13,8 @@ import (
 	"github.com/gin-gonic/gin"
 
 	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/service"
 	"github.com/goatkit/goatflow/internal/services"
 )
 
@@ -102,7 +105,7 @@ func handleTicketUpdateTestFallback(c *gin.Context, ticketID int64, updateReques
 // HandleUpdateTicketAPI handles PUT /api/v1/tickets/:id.
 //
 //	@Summary		Update ticket
-//	@Description	Update an existing ticket's properties
+//	@Description	Update an existing ticket's properties. Setting impact_id or urgency_id (null clears them), or moving a rated ticket, re-derives the priority from the priority matrix unless priority_id is set.
 //	@Tags			Tickets
 //	@Accept			json
 //	@Produce		json
@@ -254,8 +257,12 @@ func HandleUpdateTicketAPI(c *gin.Context) {
 		}
 
 		// Check granular permissions for specific field updates
-		// If changing priority, need 'priority' or 'rw' permission
-		if _, hasPriority := updateRequest["priority_id"]; hasPriority {
+		// If changing priority, need 'priority' or 'rw' permission; the
+		// impact and urgency derive the priority
+		_, hasPriority := updateRequest["priority_id"]
+		_, hasImpact := updateRequest["impact_id"]
+		_, hasUrgency := updateRequest["urgency_id"]
+		if hasPriority || hasImpact || hasUrgency {
 			canPriority, err := permSvc.CanChangePriority(userID, ticketID)
 			if err != nil {
 				c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to check permissions"})
@@ -376,6 +383,31 @@ func HandleUpdateTicketAPI(c *gin.Context) {
 		}
 	}
 
+	// The ticket type's workflow decides which state changes are allowed.
+	if stateID, ok := updateRequest["state_id"].(float64); ok &&
+		rejectTicketTypeTransition(c, db, int(ticketID), int(stateID)) {
+		return
+	}
+
+	// Closing or moving a ticket under an approval rule raises a request
+	// instead; the rest of the update waits for the agent to resubmit it.
+	if isCustomer, _ := c.Get("is_customer"); isCustomer != true {
+		if stateID, ok := updateRequest["state_id"].(float64); ok &&
+			requestTicketApproval(c, db, models.ApprovalActionClose, int(ticketID), int(stateID), userID, "") {
+			return
+		}
+		if queueID, ok := updateRequest["queue_id"].(float64); ok &&
+			requestTicketApproval(c, db, models.ApprovalActionMove, int(ticketID), int(queueID), userID, "") {
+			return
+		}
+	}
+
+	// The priority matrix may derive a new priority from the rating.
+	rating, ok := applyTicketPriorityMatrix(c, db, ticketID, updateRequest)
+	if !ok {
+		return
+	}
+
 	// Build UPDATE query dynamically
 	var updateFields []string
 	var args []interface{}
@@ -444,6 +476,11 @@ func HandleUpdateTicketAPI(c *gin.Context) {
 		})
 		return
 	}
+	if rating != nil {
+		if err := service.NewPriorityMatrixService(db).RateTicket(c.Request.Context(), rating, userID); err != nil {
+			log.Printf("update ticket: storing impact and urgency of ticket %d failed: %v", ticketID, err)
+		}
+	}
 	// Fetch updated ticket data
 	typeSelect := fmt.Sprintf("%s AS type_id", database.QualifiedTicketTypeColumn("t"))
 	query := database.ConvertPlaceholders(fmt.Sprintf(`
@@ -553,6 +590,7 @@ func HandleUpdateTicketAPI(c *gin.Context) {
 	} else {
 		responseData["responsible_user_id"] = nil
 	}
+	addTicketRating(c.Request.Context(), db, ticketID, responseData)
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -61,11 +61,15 @@ func handleTOTPStatus(c *gin.Context) {
 	totpService := service.NewTOTPService(db, "GoatFlow")
 	enabled := totpService.IsEnabled(userID)
 	remaining := totpService.GetRemainingRecoveryCodes(userID)
+	keys, _ := service.NewWebAuthnService(db).ForUser(userID).Credentials()
 
 	c.JSON(http.StatusOK, gin.H{
 		"success":                  true,
 		"enabled":                  enabled,
 		"recovery_codes_remaining": remaining,
+		"webauthn_keys":            len(keys),
+		"required":                 twoFactorRequired(c, false),
+		"session_verified":         c.GetBool("2fa_complete"),
 	})
 }
 
@@ -199,6 +203,7 @@ func handleTOTPConfirm(c *gin.Context) {
 
 	// Send security notification email
 	go send2FAEnabledNotification(db, uint(userID), c.ClientIP())
+	markSessionTwoFactorComplete(c)
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -242,6 +247,11 @@ func handleTOTPDisable(c *gin.Context) {
 		return
 	}
 
+	if twoFactorRequired(c, false) && !service.NewWebAuthnService(db).ForUser(userID).IsEnabled() {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "two-factor authentication is required for your account; add a security key first"})
+		return
+	}
+
 	totpService := service.NewTOTPService(db, "GoatFlow")
 
 	if err := totpService.Disable(userID, req.Code); err != nil {
@@ -414,7 +424,7 @@ func handleTOTPVerify(c *gin.Context) {
 		return
 	}
 
-	jwtToken, err := jwtManager.GenerateToken(uint(session.UserID), session.Username, "user", 1)
+	jwtToken, err := jwtManager.GenerateTwoFactorToken(uint(session.UserID), session.Username, session.Username, "user", false, 1)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "failed to generate token"})
 		return
@@ -496,11 +506,15 @@ func handleCustomerTOTPStatus(c *gin.Context) {
 	totpService := service.NewTOTPService(db, "GoatFlow")
 	enabled := totpService.IsEnabledForCustomer(customerLogin)
 	remaining := totpService.GetRemainingRecoveryCodesForCustomer(customerLogin)
+	keys, _ := service.NewWebAuthnService(db).ForCustomer(customerLogin).Credentials()
 
 	c.JSON(http.StatusOK, gin.H{
 		"success":                  true,
 		"enabled":                  enabled,
 		"recovery_codes_remaining": remaining,
+		"webauthn_keys":            len(keys),
+		"required":                 twoFactorRequired(c, true),
+		"session_verified":         c.GetBool("2fa_complete"),
 	})
 }
 
@@ -629,6 +643,7 @@ func handleCustomerTOTPConfirm(c *gin.Context) {
 
 	// Send security notification email
 	go sendCustomer2FAEnabledNotification(db, customerLogin, c.ClientIP())
+	markSessionTwoFactorComplete(c)
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -666,6 +681,11 @@ func handleCustomerTOTPDisable(c *gin.Context) {
 		return
 	}
 
+	if twoFactorRequired(c, true) && !service.NewWebAuthnService(db).ForCustomer(customerLogin).IsEnabled() {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "two-factor authentication is required for your account; add a security key first"})
+		return
+	}
+
 	totpService := service.NewTOTPService(db, "GoatFlow")
 
 	if err := totpService.DisableForCustomer(customerLogin, req.Code); err != nil {
@@ -753,12 +773,13 @@ This is an automated security notification.
 // handleCustomer2FAPage renders the 2FA verification page during customer login.
 func handleCustomer2FAPage(c *gin.Context) {
 	// Check for pending 2FA cookie
-	if _, err := c.Cookie("customer_2fa_pending"); err != nil {
+	token, err := c.Cookie("customer_2fa_pending")
+	if err != nil {
 		c.Redirect(http.StatusFound, "/customer/login")
 		return
 	}
 
-	getPongo2Renderer().HTML(c, http.StatusOK, "pages/customer/login_2fa.pongo2", nil)
+	getPongo2Renderer().HTML(c, http.StatusOK, "pages/customer/login_2fa.pongo2", secondFactorPageContext(c, token))
 }
 
 // handleCustomer2FAVerify verifies the 2FA code and completes customer login.
@@ -831,6 +852,12 @@ func handleCustomer2FAVerify(c *gin.Context) {
 	sessionMgr.InvalidateSession(token)
 	c.SetCookie("customer_2fa_pending", "", -1, "/", "", false, true)
 
+	completeCustomerTwoFactorLogin(c, db, session.UserLogin)
+}
+
+// completeCustomerTwoFactorLogin issues the portal session for a customer who
+// passed the second factor. Like the agent flow, the token carries the mfa claim.
+func completeCustomerTwoFactorLogin(c *gin.Context, db *sql.DB, login string) {
 	// Complete the login - issue JWT token
 	jwtManager := shared.GetJWTManager()
 	if jwtManager == nil {
@@ -841,14 +868,14 @@ func handleCustomer2FAVerify(c *gin.Context) {
 	// Look up user to get full details
 	var userID uint
 	var email, firstName, lastName string
-	query := "SELECT id, email, first_name, last_name FROM customer_user WHERE login = ?"
-	if err := db.QueryRow(query, session.UserLogin).Scan(&userID, &email, &firstName, &lastName); err != nil {
+	query := database.ConvertPlaceholders("SELECT id, email, first_name, last_name FROM customer_user WHERE login = ?")
+	if err := db.QueryRow(query, login).Scan(&userID, &email, &firstName, &lastName); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "failed to load user"})
 		return
 	}
 
 	// Generate token
-	jwtToken, err := jwtManager.GenerateTokenWithLogin(userID, session.UserLogin, email, "Customer", false, 0)
+	jwtToken, err := jwtManager.GenerateTwoFactorToken(userID, login, email, "Customer", false, 0)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "failed to generate token"})
 		return
@@ -913,20 +940,26 @@ func handleAdmin2FAOverride(c *gin.Context) {
 	}
 
 	// Check if 2FA is enabled
-	totpService := service.NewTOTPService(db, "GoatFlow")
-	if !totpService.IsEnabled(targetUserID) {
+	if !agentHasSecondFactor(db, targetUserID) {
 		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "2FA is not enabled for this user"})
 		return
 	}
 
-	// Disable 2FA (bypassing code verification)
-	if err := totpService.ForceDisable(targetUserID); err != nil {
+	// Disable 2FA (bypassing code verification) and drop all security keys
+	keys := service.NewWebAuthnService(db).ForUser(targetUserID)
+	creds, _ := keys.Credentials()
+	if err := service.NewTOTPService(db, "GoatFlow").ForceDisable(targetUserID); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "failed to disable 2FA"})
 		return
 	}
+	if err := keys.RemoveAll(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "failed to remove security keys"})
+		return
+	}
 
 	// Log the action
-	if err := logAdminAction(db, "2FADisable", "user", targetUserID, targetUser.Login, adminID, req.Reason, nil); err != nil {
+	details := map[string]interface{}{"webauthn_keys_removed": len(creds)}
+	if err := logAdminAction(db, "2FADisable", "user", targetUserID, targetUser.Login, adminID, req.Reason, details); err != nil {
 		log.Printf("Failed to log admin action: %v", err)
 		// Don't fail the request, just log the error
 	}
@@ -974,20 +1007,26 @@ func handleAdminCustomer2FAOverride(c *gin.Context) {
 	}
 
 	// Check if 2FA is enabled
-	totpService := service.NewTOTPService(db, "GoatFlow")
-	if !totpService.IsEnabledForCustomer(customerLogin) {
+	if !customerHasSecondFactor(db, customerLogin) {
 		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "2FA is not enabled for this customer"})
 		return
 	}
 
-	// Disable 2FA
-	if err := totpService.ForceDisableForCustomer(customerLogin); err != nil {
+	// Disable 2FA and drop all security keys
+	keys := service.NewWebAuthnService(db).ForCustomer(customerLogin)
+	creds, _ := keys.Credentials()
+	if err := service.NewTOTPService(db, "GoatFlow").ForceDisableForCustomer(customerLogin); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "failed to disable 2FA"})
 		return
 	}
+	if err := keys.RemoveAll(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "failed to remove security keys"})
+		return
+	}
 
 	// Log the action
-	if err := logAdminAction(db, "2FADisable", "customer", 0, customerLogin, adminID, req.Reason, nil); err != nil {
+	details := map[string]interface{}{"webauthn_keys_removed": len(creds)}
+	if err := logAdminAction(db, "2FADisable", "customer", 0, customerLogin, adminID, req.Reason, details); err != nil {
 		log.Printf("Failed to log admin action: %v", err)
 	}
 
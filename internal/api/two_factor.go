@@ -0,0 +1,117 @@
+package api
+
+import (
+	"database/sql"
+	"log"
+	"net"
+	"strings"
+
+	"github.com/flosch/pongo2/v6"
+	"github.com/gin-gonic/gin"
+
+	"github.com/goatkit/goatflow/internal/auth"
+	"github.com/goatkit/goatflow/internal/config"
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/middleware"
+	"github.com/goatkit/goatflow/internal/service"
+	"github.com/goatkit/goatflow/internal/shared"
+)
+
+// agentHasSecondFactor reports whether an agent has TOTP or a security key
+// and therefore has to pass the 2FA step at login.
+func agentHasSecondFactor(db *sql.DB, userID int) bool {
+	return service.NewTOTPService(db, "GoatFlow").IsEnabled(userID) ||
+		service.NewWebAuthnService(db).ForUser(userID).IsEnabled()
+}
+
+// customerHasSecondFactor is the customer counterpart of agentHasSecondFactor.
+func customerHasSecondFactor(db *sql.DB, login string) bool {
+	return service.NewTOTPService(db, "GoatFlow").IsEnabledForCustomer(login) ||
+		service.NewWebAuthnService(db).ForCustomer(login).IsEnabled()
+}
+
+// twoFactorRequired reports whether the policy forces the current session's
+// role to keep a second factor.
+func twoFactorRequired(c *gin.Context, customer bool) bool {
+	if customer {
+		return middleware.TwoFactorPolicy().Requires("Customer", false)
+	}
+	return middleware.TwoFactorPolicy().Requires(c.GetString("user_role"), false)
+}
+
+// secondFactorPageContext tells the 2FA login page which factors the pending
+// user can use. Without a valid pending session both are offered and the
+// verify endpoints reject the attempt.
+func secondFactorPageContext(c *gin.Context, pendingToken string) pongo2.Context {
+	ctx := pongo2.Context{"TOTPAvailable": true, "WebAuthnAvailable": false}
+	session := auth.GetTOTPSessionManager().ValidateAndGetSession(pendingToken, c.ClientIP(), c.Request.UserAgent())
+	db, err := database.GetDB()
+	if session == nil || err != nil || db == nil {
+		return ctx
+	}
+	if session.IsCustomer {
+		ctx["TOTPAvailable"] = service.NewTOTPService(db, "GoatFlow").IsEnabledForCustomer(session.UserLogin)
+		ctx["WebAuthnAvailable"] = service.NewWebAuthnService(db).ForCustomer(session.UserLogin).IsEnabled()
+	} else {
+		ctx["TOTPAvailable"] = service.NewTOTPService(db, "GoatFlow").IsEnabled(session.UserID)
+		ctx["WebAuthnAvailable"] = service.NewWebAuthnService(db).ForUser(session.UserID).IsEnabled()
+	}
+	return ctx
+}
+
+// markSessionTwoFactorComplete re-issues the caller's token with the mfa
+// claim after they enrol a second factor, so a session held back by the
+// two-factor policy is released without logging in again.
+func markSessionTwoFactorComplete(c *gin.Context) {
+	val, _ := c.Get("claims")
+	claims, ok := val.(*auth.Claims)
+	if !ok || claims.MFA {
+		return
+	}
+	jwtManager := shared.GetJWTManager()
+	if jwtManager == nil {
+		return
+	}
+	token, err := jwtManager.GenerateTwoFactorToken(claims.UserID, claims.Login, claims.Email, claims.Role, claims.IsAdmin, claims.TenantID)
+	if err != nil {
+		log.Printf("Failed to re-issue 2FA-complete token: %v", err)
+		return
+	}
+	maxAge := shared.GetSystemSessionMaxTime()
+	if strings.EqualFold(claims.Role, "Customer") {
+		c.SetCookie("customer_access_token", token, maxAge, "/", "", false, true)
+		c.SetCookie("customer_auth_token", token, maxAge, "/", "", false, true)
+	} else {
+		c.SetCookie("access_token", token, maxAge, "/", "", false, true)
+		c.SetCookie("auth_token", token, maxAge, "/", "", false, true)
+	}
+	c.Set("2fa_complete", true)
+}
+
+// webAuthnRelyingParty returns the configured relying party, defaulting the
+// ID and origin to the host the browser used to reach us.
+func webAuthnRelyingParty(c *gin.Context) auth.WebAuthnRelyingParty {
+	rp := auth.WebAuthnRelyingParty{Name: "GoatFlow"}
+	if cfg := config.Get(); cfg != nil {
+		wa := cfg.Auth.TwoFactor.WebAuthn
+		rp.ID, rp.Origins = wa.RPID, wa.Origins
+		if wa.RPName != "" {
+			rp.Name = wa.RPName
+		}
+	}
+	host := c.Request.Host
+	if rp.ID == "" {
+		rp.ID = host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			rp.ID = h
+		}
+	}
+	if len(rp.Origins) == 0 {
+		scheme := "http"
+		if c.Request.TLS != nil || strings.EqualFold(c.GetHeader("X-Forwarded-Proto"), "https") {
+			scheme = "https"
+		}
+		rp.Origins = []string{scheme + "://" + host}
+	}
+	return rp
+}
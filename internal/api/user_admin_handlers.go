@@ -0,0 +1,190 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/service"
+)
+
+// userAdminService creates a user administration service, writing 503 when
+// the database is unavailable.
+func userAdminService(c *gin.Context) *service.UserAdminService {
+	db, err := database.GetDB()
+	if err != nil || db == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"success": false, "error": "Database unavailable"})
+		return nil
+	}
+	return service.NewUserAdminService(db)
+}
+
+// writeUserAdminError maps user administration errors to responses.
+func writeUserAdminError(c *gin.Context, action string, err error) {
+	var policyErr *service.AgentPasswordPolicyError
+	switch {
+	case errors.As(err, &policyErr):
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error(), "code": policyErr.Code})
+	case errors.Is(err, service.ErrAgentInvalid), errors.Is(err, service.ErrAgentPasswordEmpty),
+		errors.Is(err, service.ErrGroupPermission):
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+	case errors.Is(err, service.ErrPasswordAccountNotFound), errors.Is(err, service.ErrGroupNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": err.Error()})
+	case errors.Is(err, service.ErrAgentExists):
+		c.JSON(http.StatusConflict, gin.H{"success": false, "error": err.Error()})
+	default:
+		log.Printf("user admin api: %s failed: %v", action, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to " + action})
+	}
+}
+
+// HandleCreateAgentAPI handles POST /api/v1/admin/agents.
+//
+//	@Summary		Create an agent
+//	@Description	Creates a valid agent with a password that meets the agent password policy and adds it to the given groups with rw.
+//	@Tags			Agent Administration
+//	@Accept			json
+//	@Produce		json
+//	@Param			agent	body		object					true	"login, first_name, last_name, title, password, groups"
+//	@Success		201		{object}	map[string]interface{}	"Created agent"
+//	@Failure		400		{object}	map[string]interface{}	"Invalid agent or password"
+//	@Failure		404		{object}	map[string]interface{}	"Unknown group"
+//	@Failure		409		{object}	map[string]interface{}	"Login already exists"
+//	@Security		BearerAuth
+//	@Router			/admin/agents [post]
+func HandleCreateAgentAPI(c *gin.Context) {
+	var req service.NewAgent
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid request: " + err.Error()})
+		return
+	}
+	svc := userAdminService(c)
+	if svc == nil {
+		return
+	}
+	id, err := svc.CreateAgent(c.Request.Context(), req, GetUserIDFromCtx(c, 1))
+	if err != nil {
+		writeUserAdminError(c, "create agent", err)
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"success": true, "data": gin.H{"id": id, "login": req.Login}})
+}
+
+// HandleSetAgentPasswordAPI handles PUT /api/v1/admin/agents/:login/password.
+//
+//	@Summary		Set an agent's password
+//	@Description	Sets a new password that meets the agent password policy and resets the failed login count. A locked agent stays locked.
+//	@Tags			Agent Administration
+//	@Accept			json
+//	@Produce		json
+//	@Param			login		path		string					true	"Agent login"
+//	@Param			password	body		object					true	"password"
+//	@Success		200			{object}	map[string]interface{}	"Password set"
+//	@Failure		400			{object}	map[string]interface{}	"Password rejected"
+//	@Failure		404			{object}	map[string]interface{}	"Unknown agent"
+//	@Security		BearerAuth
+//	@Router			/admin/agents/{login}/password [put]
+func HandleSetAgentPasswordAPI(c *gin.Context) {
+	var req struct {
+		Password string `json:"password"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid request: " + err.Error()})
+		return
+	}
+	svc := userAdminService(c)
+	if svc == nil {
+		return
+	}
+	if err := svc.SetPassword(c.Request.Context(), c.Param("login"), req.Password, GetUserIDFromCtx(c, 1)); err != nil {
+		writeUserAdminError(c, "set password", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// HandleLockAgentAPI handles PUT /api/v1/admin/agents/:login/lock.
+//
+//	@Summary		Lock an agent
+//	@Description	Sets the agent invalid-temporarily, as the failed login lockout does.
+//	@Tags			Agent Administration
+//	@Produce		json
+//	@Param			login	path		string					true	"Agent login"
+//	@Success		200		{object}	map[string]interface{}	"Agent locked"
+//	@Failure		404		{object}	map[string]interface{}	"Unknown agent"
+//	@Security		BearerAuth
+//	@Router			/admin/agents/{login}/lock [put]
+func HandleLockAgentAPI(c *gin.Context) {
+	svc := userAdminService(c)
+	if svc == nil {
+		return
+	}
+	if err := svc.Lock(c.Request.Context(), c.Param("login"), GetUserIDFromCtx(c, 1)); err != nil {
+		writeUserAdminError(c, "lock agent", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// HandleUnlockAgentAPI handles DELETE /api/v1/admin/agents/:login/lock.
+//
+//	@Summary		Unlock an agent
+//	@Description	Makes the agent valid again and resets its failed login count.
+//	@Tags			Agent Administration
+//	@Produce		json
+//	@Param			login	path		string					true	"Agent login"
+//	@Success		200		{object}	map[string]interface{}	"Agent unlocked"
+//	@Failure		404		{object}	map[string]interface{}	"Unknown agent"
+//	@Security		BearerAuth
+//	@Router			/admin/agents/{login}/lock [delete]
+func HandleUnlockAgentAPI(c *gin.Context) {
+	svc := userAdminService(c)
+	if svc == nil {
+		return
+	}
+	if err := svc.Unlock(c.Request.Context(), c.Param("login"), GetUserIDFromCtx(c, 1)); err != nil {
+		writeUserAdminError(c, "unlock agent", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// HandleAddAgentToGroupAPI handles PUT /api/v1/admin/agents/:login/groups/:group.
+//
+//	@Summary		Add an agent to a group
+//	@Description	Gives the agent a permission in the group, rw unless another one is given. Existing memberships are kept.
+//	@Tags			Agent Administration
+//	@Accept			json
+//	@Produce		json
+//	@Param			login		path		string					true	"Agent login"
+//	@Param			group		path		string					true	"Group name"
+//	@Param			permission	body		object					false	"permission"
+//	@Success		200			{object}	map[string]interface{}	"Membership added"
+//	@Failure		400			{object}	map[string]interface{}	"Unknown permission"
+//	@Failure		404			{object}	map[string]interface{}	"Unknown agent or group"
+//	@Security		BearerAuth
+//	@Router			/admin/agents/{login}/groups/{group} [put]
+func HandleAddAgentToGroupAPI(c *gin.Context) {
+	var req struct {
+		Permission string `json:"permission"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid request: " + err.Error()})
+			return
+		}
+	}
+	svc := userAdminService(c)
+	if svc == nil {
+		return
+	}
+	err := svc.AddToGroup(c.Request.Context(), c.Param("login"), c.Param("group"), req.Permission, GetUserIDFromCtx(c, 1))
+	if err != nil {
+		writeUserAdminError(c, "add group membership", err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
@@ -0,0 +1,50 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/testutil"
+)
+
+func TestUserAdminHandlers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := testutil.UseMigratedDB(t)
+
+	router := gin.New()
+	router.POST("/api/v1/admin/agents", HandleCreateAgentAPI)
+	router.PUT("/api/v1/admin/agents/:login/lock", HandleLockAgentAPI)
+	router.DELETE("/api/v1/admin/agents/:login/lock", HandleUnlockAgentAPI)
+	router.PUT("/api/v1/admin/agents/:login/groups/:group", HandleAddAgentToGroupAPI)
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	agent := `{"login":"ann@example.com","first_name":"Ann","last_name":"Lee","password":"Secret123456","groups":["users"]}`
+	w := do(http.MethodPost, "/api/v1/admin/agents", agent)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	assert.Equal(t, http.StatusConflict, do(http.MethodPost, "/api/v1/admin/agents", agent).Code)
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPost, "/api/v1/admin/agents", `{"login":"bob","password":"x"}`).Code)
+
+	w = do(http.MethodPut, "/api/v1/admin/agents/ann@example.com/lock", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var validID int
+	require.NoError(t, db.QueryRow(`SELECT valid_id FROM users WHERE login = 'ann@example.com'`).Scan(&validID))
+	assert.Equal(t, 3, validID)
+	assert.Equal(t, http.StatusOK, do(http.MethodDelete, "/api/v1/admin/agents/ann@example.com/lock", "").Code)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodPut, "/api/v1/admin/agents/bob/lock", "").Code)
+
+	assert.Equal(t, http.StatusOK, do(http.MethodPut, "/api/v1/admin/agents/ann@example.com/groups/admin", "").Code)
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPut, "/api/v1/admin/agents/ann@example.com/groups/admin", `{"permission":"all"}`).Code)
+	assert.Equal(t, http.StatusNotFound, do(http.MethodPut, "/api/v1/admin/agents/ann@example.com/groups/nope", "").Code)
+}
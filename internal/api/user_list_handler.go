@@ -23,7 +23,11 @@ import (
 //	@Param			per_page	query		int		false	"Items per page"	default(20)
 //	@Param			search		query		string	false	"Search in login, name, email"
 //	@Param			group_id	query		int		false	"Filter by group membership"
+//	@Param			cursor		query		string	false	"Cursor from pagination.next_cursor; pass it empty to start cursor pagination"
+//	@Param			fields		query		string	false	"Comma-separated fields to return (id is always returned)"
 //	@Success		200			{object}	map[string]interface{}	"List of users"
+//	@Header			200			{string}	Link					"RFC 5988 links to the first, previous, next and last pages"
+//	@Failure		400			{object}	map[string]interface{}	"Invalid cursor or fields parameter"
 //	@Failure		401			{object}	map[string]interface{}	"Unauthorized"
 //	@Security		BearerAuth
 //	@Router			/users [get]
@@ -58,6 +62,18 @@ func HandleListUsersAPI(c *gin.Context) {
 	validFilter := c.Query("valid") // "1" for valid only, "2" for invalid only, "" for all
 	groupID := c.Query("group_id")
 
+	fields, err := parseListNames(c, "fields", userListFields)
+	if err != nil {
+		listParamError(c, err)
+		return
+	}
+	// Users are listed by ID, so the cursor only carries the last ID.
+	cursorMode, cursor, err := listCursorParam(c, "id", "asc")
+	if err != nil {
+		listParamError(c, err)
+		return
+	}
+
 	// Get database connection
 	db, err := database.GetDB()
 	if err != nil || db == nil {
@@ -94,8 +110,8 @@ func HandleListUsersAPI(c *gin.Context) {
 	if search != "" {
 		searchPattern := "%" + search + "%"
 		searchClauses := make([]string, 0, 4)
-		fields := []string{"u.login", "u.first_name", "u.last_name"}
-		for _, field := range fields {
+		columns := []string{"u.login", "u.first_name", "u.last_name"}
+		for _, field := range columns {
 			searchClauses = append(searchClauses, fmt.Sprintf("LOWER(%s) LIKE LOWER(?)", field))
 			args = append(args, searchPattern)
 		}
@@ -110,41 +126,54 @@ func HandleListUsersAPI(c *gin.Context) {
 		}
 	}
 
-	// Combine WHERE clauses
-	if len(where) > 0 {
-		query += " WHERE " + strings.Join(where, " AND ")
-	}
+	// Get total count; cursor pages skip it
+	var total int
+	if !cursorMode {
+		countQuery := "SELECT COUNT(DISTINCT u.id) FROM users u"
+		if groupID != "" {
+			countQuery += " INNER JOIN group_user gu ON u.id = gu.user_id"
+		}
+		if len(where) > 0 {
+			countQuery += " WHERE " + strings.Join(where, " AND ")
+		}
+		countQuery = database.ConvertPlaceholders(countQuery)
 
-	// Get total count
-	countQuery := "SELECT COUNT(DISTINCT u.id) FROM users u"
-	if groupID != "" {
-		countQuery += " INNER JOIN group_user gu ON u.id = gu.user_id"
+		err = db.QueryRow(countQuery, args...).Scan(&total)
+		if err != nil {
+			if shouldFallbackToMock(err) {
+				respondWithMockUsers(c, page, perPage)
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"error":   "Failed to count users",
+			})
+			return
+		}
 	}
-	if len(where) > 0 {
-		countQuery += " WHERE " + strings.Join(where, " AND ")
+
+	if cursor != nil {
+		where = append(where, "u.id > ?")
+		args = append(args, cursor.ID)
 	}
-	countQuery = database.ConvertPlaceholders(countQuery)
 
-	var total int
-	err = db.QueryRow(countQuery, args...).Scan(&total)
-	if err != nil {
-		if shouldFallbackToMock(err) {
-			respondWithMockUsers(c, page, perPage)
-			return
-		}
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"success": false,
-			"error":   "Failed to count users",
-		})
-		return
+	// Combine WHERE clauses
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
 	}
 
 	// Add pagination
-	offset := (page - 1) * perPage
-	query += " ORDER BY u.id LIMIT ?"
-	args = append(args, perPage)
-	query += " OFFSET ?"
-	args = append(args, offset)
+	if cursorMode {
+		// One extra row tells whether there is a next page.
+		query += " ORDER BY u.id LIMIT ?"
+		args = append(args, perPage+1)
+	} else {
+		offset := (page - 1) * perPage
+		query += " ORDER BY u.id LIMIT ?"
+		args = append(args, perPage)
+		query += " OFFSET ?"
+		args = append(args, offset)
+	}
 
 	// Convert placeholders for the database
 	query = database.ConvertPlaceholders(query)
@@ -210,6 +239,10 @@ func HandleListUsersAPI(c *gin.Context) {
 		}
 
 		// Get user's groups
+		if fields != nil && !fields["groups"] {
+			users = append(users, userMap)
+			continue
+		}
 		groupQuery := database.ConvertPlaceholders(`
 			SELECT g.id, g.name
 			FROM groups g
@@ -241,8 +274,32 @@ func HandleListUsersAPI(c *gin.Context) {
 	}
 	_ = rows.Err() //nolint:errcheck // Check for iteration errors
 
+	nextCursor := ""
+	if cursorMode && len(users) > perPage {
+		users = users[:perPage]
+		nextCursor = listSort{Column: "u.id"}.cursorAfter("id", "asc", users[perPage-1])
+	}
+	for i := range users {
+		users[i] = selectListFields(users[i], fields, nil)
+	}
+
+	if cursorMode {
+		setCursorLinkHeader(c, nextCursor)
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"data":    users,
+			"pagination": gin.H{
+				"per_page":    perPage,
+				"next_cursor": nextCursor,
+				"has_next":    nextCursor != "",
+			},
+		})
+		return
+	}
+
 	// Calculate pagination info
 	totalPages := (total + perPage - 1) / perPage
+	setPageLinkHeader(c, page, totalPages)
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
@@ -258,6 +315,11 @@ func HandleListUsersAPI(c *gin.Context) {
 	})
 }
 
+// userListFields are the fields accepted by fields= on the user list.
+var userListFields = []string{
+	"login", "first_name", "last_name", "valid_id", "valid", "create_time", "change_time", "groups",
+}
+
 func respondWithMockUsers(c *gin.Context, page, perPage int) {
 	users := []gin.H{
 		{"id": 1, "login": "admin", "valid_id": 1, "valid": true, "groups": []gin.H{{"id": 1, "name": "Admin"}}},
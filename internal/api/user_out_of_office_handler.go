@@ -0,0 +1,170 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/service"
+)
+
+// outOfOfficeIsAdmin reports whether an agent may manage other agents'
+// out-of-office settings. Tests replace it to avoid a database.
+var outOfOfficeIsAdmin = func(ctx context.Context, userID uint) (bool, error) {
+	db, err := database.GetDB()
+	if err != nil || db == nil {
+		return false, err
+	}
+	return service.NewQueueAccessService(db).IsAdmin(ctx, userID)
+}
+
+func outOfOfficeService(c *gin.Context) *service.OutOfOfficeService {
+	db, err := database.GetDB()
+	if err != nil || db == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"success": false, "error": "Database unavailable"})
+		return nil
+	}
+	return service.NewOutOfOfficeService(db)
+}
+
+// outOfOfficeUserID parses the :id path parameter and checks that the caller
+// is that agent or an admin, writing the error response otherwise.
+func outOfOfficeUserID(c *gin.Context) (int, bool) {
+	if kbIsCustomer(c) {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": "Agent access required"})
+		return 0, false
+	}
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil || id <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid user ID"})
+		return 0, false
+	}
+	callerID := GetUserIDFromCtx(c, 0)
+	if callerID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"success": false, "error": "Authentication required"})
+		return 0, false
+	}
+	if callerID == id {
+		return id, true
+	}
+	if isAdmin, _ := c.Get("isInAdminGroup"); isAdmin == true {
+		return id, true
+	}
+	isAdmin, err := outOfOfficeIsAdmin(c.Request.Context(), uint(callerID))
+	if err != nil {
+		log.Printf("out-of-office api: admin check for user %d failed: %v", callerID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to check permissions"})
+		return 0, false
+	}
+	if !isAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "error": "Only admins can manage other agents' out-of-office settings"})
+		return 0, false
+	}
+	return id, true
+}
+
+// HandleGetUserOutOfOfficeAPI handles GET /api/v1/users/:id/out-of-office.
+//
+//	@Summary		Get out-of-office settings
+//	@Description	Returns the agent's out-of-office period and substitute. Agents can read their own settings; admins can read anyone's.
+//	@Tags			Users
+//	@Produce		json
+//	@Param			id	path		int	true	"User ID"
+//	@Success		200	{object}	map[string]interface{}	"Out-of-office settings"
+//	@Failure		403	{object}	map[string]interface{}	"Not allowed"
+//	@Security		BearerAuth
+//	@Router			/users/{id}/out-of-office [get]
+func HandleGetUserOutOfOfficeAPI(c *gin.Context) {
+	userID, ok := outOfOfficeUserID(c)
+	if !ok {
+		return
+	}
+	svc := outOfOfficeService(c)
+	if svc == nil {
+		return
+	}
+	ooo, err := svc.Get(c.Request.Context(), userID)
+	if err != nil {
+		log.Printf("out-of-office api: loading user %d failed: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to load out-of-office settings"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": ooo})
+}
+
+// HandleUpdateUserOutOfOfficeAPI handles PUT /api/v1/users/:id/out-of-office.
+//
+//	@Summary		Set out-of-office settings
+//	@Description	Replaces the agent's out-of-office period and substitute. While the period is active, assignments and notifications go to the substitute.
+//	@Tags			Users
+//	@Accept			json
+//	@Produce		json
+//	@Param			id		path		int		true	"User ID"
+//	@Param			body	body		object	true	"enabled, start and end (YYYY-MM-DD), substitute_id"
+//	@Success		200		{object}	map[string]interface{}	"Out-of-office settings"
+//	@Failure		400		{object}	map[string]interface{}	"Invalid period or substitute"
+//	@Failure		403		{object}	map[string]interface{}	"Not allowed"
+//	@Failure		404		{object}	map[string]interface{}	"User not found"
+//	@Security		BearerAuth
+//	@Router			/users/{id}/out-of-office [put]
+func HandleUpdateUserOutOfOfficeAPI(c *gin.Context) {
+	userID, ok := outOfOfficeUserID(c)
+	if !ok {
+		return
+	}
+	var req struct {
+		Enabled      bool   `json:"enabled"`
+		Start        string `json:"start"`
+		End          string `json:"end"`
+		SubstituteID int    `json:"substitute_id"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "Invalid request: " + err.Error()})
+		return
+	}
+	svc := outOfOfficeService(c)
+	if svc == nil {
+		return
+	}
+
+	ooo, err := svc.Set(c.Request.Context(), service.OutOfOffice{
+		UserID:       userID,
+		Enabled:      req.Enabled,
+		Start:        req.Start,
+		End:          req.End,
+		SubstituteID: req.SubstituteID,
+	})
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": ooo})
+	case errors.Is(err, service.ErrOutOfOfficeUserNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": "User not found"})
+	case errors.Is(err, service.ErrOutOfOfficeInvalidPeriod),
+		errors.Is(err, service.ErrOutOfOfficeInvalidSubstitute):
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": err.Error()})
+	default:
+		log.Printf("out-of-office api: updating user %d failed: %v", userID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "Failed to update out-of-office settings"})
+	}
+}
+
+// resolveAssignee returns the agent to assign instead of agentID while it is
+// out of office. It writes 409 and returns false when the agent is absent
+// with no available substitute. Lookup failures keep agentID.
+func resolveAssignee(c *gin.Context, svc *service.OutOfOfficeService, agentID int) (int, bool) {
+	resolved, err := svc.ResolveAgent(c.Request.Context(), agentID)
+	if errors.Is(err, service.ErrAgentOutOfOffice) {
+		c.JSON(http.StatusConflict, gin.H{"success": false, "error": err.Error()})
+		return 0, false
+	}
+	if err != nil {
+		log.Printf("out-of-office: resolving agent %d failed: %v", agentID, err)
+		return agentID, true
+	}
+	return resolved, true
+}
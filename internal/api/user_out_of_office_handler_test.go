@@ -0,0 +1,55 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOutOfOfficeUserIDPermissions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	orig := outOfOfficeIsAdmin
+	t.Cleanup(func() { outOfOfficeIsAdmin = orig })
+	outOfOfficeIsAdmin = func(_ context.Context, userID uint) (bool, error) {
+		return userID == 1, nil
+	}
+
+	cases := []struct {
+		name   string
+		param  string
+		setup  func(c *gin.Context)
+		wantOK bool
+		code   int
+	}{
+		{"own settings", "5", func(c *gin.Context) { c.Set("user_id", 5) }, true, http.StatusOK},
+		{"other agent", "6", func(c *gin.Context) { c.Set("user_id", 5) }, false, http.StatusForbidden},
+		{"admin by lookup", "6", func(c *gin.Context) { c.Set("user_id", 1) }, true, http.StatusOK},
+		{"admin by context", "6", func(c *gin.Context) {
+			c.Set("user_id", 5)
+			c.Set("isInAdminGroup", true)
+		}, true, http.StatusOK},
+		{"customer", "5", func(c *gin.Context) {
+			c.Set("user_id", 5)
+			c.Set("is_customer", true)
+		}, false, http.StatusForbidden},
+		{"unauthenticated", "5", func(c *gin.Context) {}, false, http.StatusUnauthorized},
+		{"invalid id", "abc", func(c *gin.Context) { c.Set("user_id", 5) }, false, http.StatusBadRequest},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/api/v1/users/"+tc.param+"/out-of-office", nil)
+			c.Params = gin.Params{{Key: "id", Value: tc.param}}
+			tc.setup(c)
+
+			_, ok := outOfOfficeUserID(c)
+			assert.Equal(t, tc.wantOK, ok)
+			assert.Equal(t, tc.code, w.Code)
+		})
+	}
+}
@@ -2,6 +2,7 @@ package v1
 
 import (
 	"encoding/base64"
+	"log"
 	"net/http"
 	"strconv"
 	"time"
@@ -9,6 +10,8 @@ import (
 	"github.com/gin-gonic/gin"
 
 	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/preview"
+	"github.com/goatkit/goatflow/internal/storage"
 )
 
 // File handlers - serve article attachments from database.
@@ -45,6 +48,7 @@ func (router *APIRouter) handleDownloadFile(c *gin.Context) {
 		sendError(c, http.StatusNotFound, "File not found")
 		return
 	}
+	content = storage.DecodeBytes(content)
 
 	c.Header("Content-Type", contentType)
 	c.Header("Content-Disposition", "attachment; filename=\""+filename+"\"")
@@ -76,6 +80,11 @@ func (router *APIRouter) handleDeleteFile(c *gin.Context) {
 		sendError(c, http.StatusNotFound, "File not found")
 		return
 	}
+	if svc := preview.Default(); svc != nil {
+		if err := svc.Invalidate(c.Request.Context(), fileID); err != nil {
+			log.Printf("files api: removing cached previews of file %d failed: %v", fileID, err)
+		}
+	}
 
 	c.JSON(http.StatusNoContent, nil)
 }
@@ -96,7 +105,10 @@ func (router *APIRouter) handleGetFileInfo(c *gin.Context) {
 
 	query := database.ConvertQuery(`
 		SELECT adma.id, adma.article_id, adma.filename, adma.content_type,
-			LENGTH(adma.content) as size, adma.create_time, u.login
+			LENGTH(adma.content) as size, adma.create_time, u.login,
+			(SELECT s.status FROM attachment_scan s
+			 WHERE s.article_id = adma.article_id AND s.filename = adma.filename
+			 ORDER BY s.id DESC LIMIT 1)
 		FROM article_data_mime_attachment adma
 		LEFT JOIN article a ON a.id = adma.article_id
 		LEFT JOIN users u ON u.id = a.create_by
@@ -107,9 +119,9 @@ func (router *APIRouter) handleGetFileInfo(c *gin.Context) {
 	var filename, contentType string
 	var size int64
 	var createTime time.Time
-	var createdBy *string
+	var createdBy, scanStatus *string
 
-	if err := db.QueryRow(query, fileID).Scan(&id, &articleID, &filename, &contentType, &size, &createTime, &createdBy); err != nil {
+	if err := db.QueryRow(query, fileID).Scan(&id, &articleID, &filename, &contentType, &size, &createTime, &createdBy, &scanStatus); err != nil {
 		sendError(c, http.StatusNotFound, "File not found")
 		return
 	}
@@ -125,6 +137,9 @@ func (router *APIRouter) handleGetFileInfo(c *gin.Context) {
 	if createdBy != nil {
 		file["created_by"] = *createdBy
 	}
+	if scanStatus != nil {
+		file["scan_status"] = *scanStatus
+	}
 
 	// Generate download URL
 	file["download_url"] = "/api/v1/files/" + strconv.Itoa(id) + "/download"
@@ -134,7 +149,7 @@ func (router *APIRouter) handleGetFileInfo(c *gin.Context) {
 		var content []byte
 		contentQuery := database.ConvertQuery(`SELECT content FROM article_data_mime_attachment WHERE id = ?`)
 		if db.QueryRow(contentQuery, fileID).Scan(&content) == nil {
-			file["content_base64"] = base64.StdEncoding.EncodeToString(content)
+			file["content_base64"] = base64.StdEncoding.EncodeToString(storage.DecodeBytes(content))
 		}
 	}
 
@@ -8,6 +8,7 @@ import (
 	"github.com/gin-gonic/gin"
 
 	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/services"
 )
 
 // handleListQueues returns all queues the user has access to.
@@ -268,6 +269,7 @@ func (router *APIRouter) handleUpdateQueue(c *gin.Context) {
 		sendError(c, http.StatusNotFound, "Queue not found")
 		return
 	}
+	services.InvalidateQueuePermissions()
 
 	c.JSON(http.StatusOK, APIResponse{
 		Success: true,
@@ -316,6 +318,7 @@ func (router *APIRouter) handleDeleteQueue(c *gin.Context) {
 		sendError(c, http.StatusNotFound, "Queue not found")
 		return
 	}
+	services.InvalidateQueuePermissions()
 
 	c.JSON(http.StatusOK, APIResponse{
 		Success: true,
@@ -97,12 +97,14 @@ func (router *APIRouter) handleGlobalSearch(c *gin.Context) {
 		}
 	}
 
-	// Search articles
+	// Search articles; email replies match on what the sender wrote, not on
+	// the messages they quoted
 	articleQuery := database.ConvertQuery(`
 		SELECT a.id, adm.a_subject, a.ticket_id
 		FROM article a
 		JOIN article_data_mime adm ON adm.article_id = a.id
-		WHERE adm.a_subject LIKE ? OR adm.a_body LIKE ?
+		LEFT JOIN article_visible_text avt ON avt.article_id = a.id
+		WHERE adm.a_subject LIKE ? OR COALESCE(avt.visible_text, adm.a_body) LIKE ?
 		ORDER BY a.create_time DESC
 		LIMIT ?
 	`)
@@ -16,6 +16,7 @@ import (
 	"github.com/goatkit/goatflow/internal/middleware"
 	"github.com/goatkit/goatflow/internal/models"
 	"github.com/goatkit/goatflow/internal/service/ticket_number"
+	"github.com/goatkit/goatflow/internal/storage"
 )
 
 // HandleListTickets returns a paginated list of tickets (exported for tests).
@@ -1440,6 +1441,9 @@ func (router *APIRouter) handleGetTicketArticles(c *gin.Context) {
 		if err := rows.Scan(&id, &tktID, &from, &to, &subject, &body, &senderType, &channel, &visibleForCustomer, &createdAt); err != nil {
 			continue
 		}
+		if body != nil {
+			*body = storage.DecodeText(*body)
+		}
 
 		articles = append(articles, gin.H{
 			"id":          id,
@@ -1506,6 +1510,9 @@ func (router *APIRouter) handleGetTicketArticle(c *gin.Context) {
 		sendError(c, http.StatusNotFound, "Article not found")
 		return
 	}
+	if body != nil {
+		*body = storage.DecodeText(*body)
+	}
 
 	sendSuccess(c, gin.H{
 		"id":          id,
@@ -0,0 +1,488 @@
+package api
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/goatkit/goatflow/internal/auth"
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/repository"
+	"github.com/goatkit/goatflow/internal/routing"
+	"github.com/goatkit/goatflow/internal/service"
+	"github.com/goatkit/goatflow/internal/shared"
+)
+
+func init() {
+	// Agent security key management
+	routing.RegisterHandler("handleWebAuthnCredentials", handleWebAuthnCredentials)
+	routing.RegisterHandler("handleWebAuthnRegisterBegin", handleWebAuthnRegisterBegin)
+	routing.RegisterHandler("handleWebAuthnRegisterFinish", handleWebAuthnRegisterFinish)
+	routing.RegisterHandler("handleWebAuthnRemove", handleWebAuthnRemove)
+
+	// Customer security key management
+	routing.RegisterHandler("handleCustomerWebAuthnCredentials", handleCustomerWebAuthnCredentials)
+	routing.RegisterHandler("handleCustomerWebAuthnRegisterBegin", handleCustomerWebAuthnRegisterBegin)
+	routing.RegisterHandler("handleCustomerWebAuthnRegisterFinish", handleCustomerWebAuthnRegisterFinish)
+	routing.RegisterHandler("handleCustomerWebAuthnRemove", handleCustomerWebAuthnRemove)
+
+	// Security key as second factor during login
+	routing.RegisterHandler("handleWebAuthnLoginBegin", handleWebAuthnLoginBegin)
+	routing.RegisterHandler("handleWebAuthnLoginFinish", handleWebAuthnLoginFinish)
+	routing.RegisterHandler("handleCustomerWebAuthnLoginBegin", handleCustomerWebAuthnLoginBegin)
+	routing.RegisterHandler("handleCustomerWebAuthnLoginFinish", handleCustomerWebAuthnLoginFinish)
+}
+
+// webAuthnTimeoutMS is the ceremony timeout suggested to the browser.
+const webAuthnTimeoutMS = int(auth.WebAuthnChallengeTTL / 1e6)
+
+// webAuthnAccount is the agent or customer whose security keys a request manages.
+type webAuthnAccount struct {
+	userID     int
+	login      string
+	isCustomer bool
+	password   string // stored hash, agents only
+	svc        *service.WebAuthnService
+}
+
+// challengeKey scopes registration challenges to the account.
+func (a *webAuthnAccount) challengeKey() string {
+	if a.isCustomer {
+		return "register:customer:" + a.login
+	}
+	return "register:agent:" + strconv.Itoa(a.userID)
+}
+
+// userHandle is an opaque, stable identifier for the authenticator; it must
+// not reveal the login, so it is a hash of the account key.
+func (a *webAuthnAccount) userHandle() string {
+	sum := sha256.Sum256([]byte(a.challengeKey()))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func (a *webAuthnAccount) verifyPassword(db *sql.DB, password string) bool {
+	if a.isCustomer {
+		return verifyCustomerPassword(db, a.login, password)
+	}
+	return auth.NewPasswordHasher().VerifyPassword(password, a.password)
+}
+
+func (a *webAuthnAccount) hasTOTP(db *sql.DB) bool {
+	totpService := service.NewTOTPService(db, "GoatFlow")
+	if a.isCustomer {
+		return totpService.IsEnabledForCustomer(a.login)
+	}
+	return totpService.IsEnabled(a.userID)
+}
+
+func (a *webAuthnAccount) audit(c *gin.Context, event string, success bool, details string) {
+	auth.LogTOTPAuditEvent(auth.TOTPAuditEvent{
+		EventType:  event,
+		UserID:     a.userID,
+		UserLogin:  a.login,
+		IsCustomer: a.isCustomer,
+		ClientIP:   c.ClientIP(),
+		UserAgent:  c.Request.UserAgent(),
+		Success:    success,
+		Details:    details,
+	})
+}
+
+// resolveWebAuthnAccount loads the logged-in agent or customer. It writes the
+// error response and returns nil if there is none.
+func resolveWebAuthnAccount(c *gin.Context, customer bool) (*webAuthnAccount, *sql.DB) {
+	var login string
+	var userID int
+	if customer {
+		login = getCustomerLogin(c)
+	} else {
+		userID = getTOTPUserID(c)
+	}
+	if login == "" && userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"success": false, "error": "unauthorized"})
+		return nil, nil
+	}
+
+	db, err := database.GetDB()
+	if err != nil || db == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "database unavailable"})
+		return nil, nil
+	}
+	if customer {
+		return &webAuthnAccount{login: login, isCustomer: true, svc: service.NewWebAuthnService(db).ForCustomer(login)}, db
+	}
+
+	user, err := repository.NewUserRepository(db).GetByID(uint(userID))
+	if err != nil || user == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"success": false, "error": "user not found"})
+		return nil, nil
+	}
+	return &webAuthnAccount{
+		userID:   userID,
+		login:    user.Login,
+		password: user.Password,
+		svc:      service.NewWebAuthnService(db).ForUser(userID),
+	}, db
+}
+
+func handleWebAuthnCredentials(c *gin.Context)         { webAuthnCredentials(c, false) }
+func handleCustomerWebAuthnCredentials(c *gin.Context) { webAuthnCredentials(c, true) }
+
+// webAuthnCredentials lists the caller's security keys without key material.
+func webAuthnCredentials(c *gin.Context, customer bool) {
+	account, _ := resolveWebAuthnAccount(c, customer)
+	if account == nil {
+		return
+	}
+	creds, err := account.svc.Credentials()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "failed to load security keys"})
+		return
+	}
+
+	items := make([]gin.H, 0, len(creds))
+	for _, cred := range creds {
+		items = append(items, gin.H{
+			"id":           cred.ID,
+			"name":         cred.Name,
+			"created_at":   cred.CreatedAt,
+			"last_used_at": cred.LastUsedAt,
+		})
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": items})
+}
+
+func handleWebAuthnRegisterBegin(c *gin.Context)         { webAuthnRegisterBegin(c, false) }
+func handleCustomerWebAuthnRegisterBegin(c *gin.Context) { webAuthnRegisterBegin(c, true) }
+
+// webAuthnRegisterBegin returns PublicKeyCredentialCreationOptions with all
+// binary fields base64url encoded. Like TOTP setup it requires the password.
+func webAuthnRegisterBegin(c *gin.Context, customer bool) {
+	var req struct {
+		Password string `json:"password" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "password is required"})
+		return
+	}
+	account, db := resolveWebAuthnAccount(c, customer)
+	if account == nil {
+		return
+	}
+	if !account.verifyPassword(db, req.Password) {
+		c.JSON(http.StatusUnauthorized, gin.H{"success": false, "error": "incorrect password"})
+		return
+	}
+
+	creds, err := account.svc.Credentials()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "failed to load security keys"})
+		return
+	}
+	challenge, err := auth.GetWebAuthnChallengeStore().Issue(account.challengeKey())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "failed to create challenge"})
+		return
+	}
+
+	rp := webAuthnRelyingParty(c)
+	params := make([]gin.H, 0, len(auth.WebAuthnAlgorithms))
+	for _, alg := range auth.WebAuthnAlgorithms {
+		params = append(params, gin.H{"type": "public-key", "alg": alg})
+	}
+	exclude := make([]gin.H, 0, len(creds))
+	for _, cred := range creds {
+		exclude = append(exclude, gin.H{"type": "public-key", "id": cred.ID})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"options": gin.H{
+			"challenge": challenge,
+			"rp":        gin.H{"id": rp.ID, "name": rp.Name},
+			"user": gin.H{
+				"id":          account.userHandle(),
+				"name":        account.login,
+				"displayName": account.login,
+			},
+			"pubKeyCredParams":   params,
+			"timeout":            webAuthnTimeoutMS,
+			"attestation":        "none",
+			"excludeCredentials": exclude,
+			"authenticatorSelection": gin.H{
+				"residentKey":      "discouraged",
+				"userVerification": "preferred",
+			},
+		},
+	})
+}
+
+func handleWebAuthnRegisterFinish(c *gin.Context)         { webAuthnRegisterFinish(c, false) }
+func handleCustomerWebAuthnRegisterFinish(c *gin.Context) { webAuthnRegisterFinish(c, true) }
+
+// webAuthnRegisterFinish verifies the authenticator's response and stores
+// the new key. Registering a key completes 2FA for the current session.
+func webAuthnRegisterFinish(c *gin.Context, customer bool) {
+	var req struct {
+		Name              string `json:"name"`
+		ClientDataJSON    string `json:"client_data_json" binding:"required"`
+		AttestationObject string `json:"attestation_object" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "client_data_json and attestation_object are required"})
+		return
+	}
+	account, _ := resolveWebAuthnAccount(c, customer)
+	if account == nil {
+		return
+	}
+
+	challenge, ok := auth.GetWebAuthnChallengeStore().Take(account.challengeKey())
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "registration expired, please start again"})
+		return
+	}
+	clientData, err1 := auth.DecodeWebAuthnBase64(req.ClientDataJSON)
+	attestation, err2 := auth.DecodeWebAuthnBase64(req.AttestationObject)
+	if err1 != nil || err2 != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "invalid encoding"})
+		return
+	}
+
+	cred, err := webAuthnRelyingParty(c).VerifyRegistration(challenge, clientData, attestation)
+	if err != nil {
+		account.audit(c, auth.AuditWebAuthnRegistered, false, err.Error())
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "security key could not be verified"})
+		return
+	}
+	if err := account.svc.AddCredential(cred, req.Name); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, service.ErrWebAuthnCredentialExists) || errors.Is(err, service.ErrWebAuthnCredentialLimit) {
+			status = http.StatusBadRequest
+		}
+		c.JSON(status, gin.H{"success": false, "error": err.Error()})
+		return
+	}
+
+	account.audit(c, auth.AuditWebAuthnRegistered, true, cred.Name)
+	markSessionTwoFactorComplete(c)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    gin.H{"id": cred.ID, "name": cred.Name, "created_at": cred.CreatedAt},
+		"message": "Security key registered.",
+	})
+}
+
+func handleWebAuthnRemove(c *gin.Context)         { webAuthnRemove(c, false) }
+func handleCustomerWebAuthnRemove(c *gin.Context) { webAuthnRemove(c, true) }
+
+// webAuthnRemove deletes one security key. The password is required, and the
+// last second factor cannot be removed while the policy requires one.
+func webAuthnRemove(c *gin.Context, customer bool) {
+	var req struct {
+		Password string `json:"password" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "password is required"})
+		return
+	}
+	account, db := resolveWebAuthnAccount(c, customer)
+	if account == nil {
+		return
+	}
+	if !account.verifyPassword(db, req.Password) {
+		c.JSON(http.StatusUnauthorized, gin.H{"success": false, "error": "incorrect password"})
+		return
+	}
+
+	creds, err := account.svc.Credentials()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "failed to load security keys"})
+		return
+	}
+	id := c.Param("id")
+	if auth.FindWebAuthnCredential(creds, id) == nil {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "error": service.ErrWebAuthnCredentialNotFound.Error()})
+		return
+	}
+	if len(creds) == 1 && !account.hasTOTP(db) && twoFactorRequired(c, customer) {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "two-factor authentication is required for your account; add another method first"})
+		return
+	}
+	if err := account.svc.RemoveCredential(id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "failed to remove security key"})
+		return
+	}
+
+	account.audit(c, auth.AuditWebAuthnRemoved, true, id)
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Security key removed."})
+}
+
+// webAuthnLoginCookie names the pending 2FA cookie for each login flow.
+func webAuthnLoginCookie(customer bool) string {
+	if customer {
+		return "customer_2fa_pending"
+	}
+	return "2fa_pending"
+}
+
+// webAuthnPendingLogin returns the pending 2FA session for the login flow,
+// writing a 401 and clearing the cookie if it is missing or invalid.
+func webAuthnPendingLogin(c *gin.Context, customer bool) (*auth.PendingTOTPSession, string) {
+	cookie := webAuthnLoginCookie(customer)
+	token, err := c.Cookie(cookie)
+	if err != nil || token == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"success": false, "error": "no pending 2FA session"})
+		return nil, ""
+	}
+	session := auth.GetTOTPSessionManager().ValidateAndGetSession(token, c.ClientIP(), c.Request.UserAgent())
+	if session == nil || session.IsCustomer != customer {
+		c.SetCookie(cookie, "", -1, "/", "", false, true)
+		c.JSON(http.StatusUnauthorized, gin.H{"success": false, "error": "invalid or expired 2FA session"})
+		return nil, ""
+	}
+	return session, token
+}
+
+func webAuthnLoginService(db *sql.DB, session *auth.PendingTOTPSession) *service.WebAuthnService {
+	if session.IsCustomer {
+		return service.NewWebAuthnService(db).ForCustomer(session.UserLogin)
+	}
+	return service.NewWebAuthnService(db).ForUser(session.UserID)
+}
+
+func handleWebAuthnLoginBegin(c *gin.Context)         { webAuthnLoginBegin(c, false) }
+func handleCustomerWebAuthnLoginBegin(c *gin.Context) { webAuthnLoginBegin(c, true) }
+
+// webAuthnLoginBegin returns PublicKeyCredentialRequestOptions for the
+// pending login, limited to the user's registered keys.
+func webAuthnLoginBegin(c *gin.Context, customer bool) {
+	session, token := webAuthnPendingLogin(c, customer)
+	if session == nil {
+		return
+	}
+	db, err := database.GetDB()
+	if err != nil || db == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "database unavailable"})
+		return
+	}
+	creds, err := webAuthnLoginService(db, session).Credentials()
+	if err != nil || len(creds) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "no security keys registered"})
+		return
+	}
+	challenge, err := auth.GetWebAuthnChallengeStore().Issue("login:" + token)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "failed to create challenge"})
+		return
+	}
+
+	allow := make([]gin.H, 0, len(creds))
+	for _, cred := range creds {
+		allow = append(allow, gin.H{"type": "public-key", "id": cred.ID})
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"options": gin.H{
+			"challenge":        challenge,
+			"rpId":             webAuthnRelyingParty(c).ID,
+			"timeout":          webAuthnTimeoutMS,
+			"allowCredentials": allow,
+			"userVerification": "preferred",
+		},
+	})
+}
+
+func handleWebAuthnLoginFinish(c *gin.Context)         { webAuthnLoginFinish(c, false) }
+func handleCustomerWebAuthnLoginFinish(c *gin.Context) { webAuthnLoginFinish(c, true) }
+
+// webAuthnLoginFinish verifies the assertion and completes the login. Failed
+// assertions count against the same attempt limit as TOTP codes.
+func webAuthnLoginFinish(c *gin.Context, customer bool) {
+	session, token := webAuthnPendingLogin(c, customer)
+	if session == nil {
+		return
+	}
+	var req struct {
+		ID                string `json:"id" binding:"required"`
+		ClientDataJSON    string `json:"client_data_json" binding:"required"`
+		AuthenticatorData string `json:"authenticator_data" binding:"required"`
+		Signature         string `json:"signature" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "incomplete security key response"})
+		return
+	}
+	db, err := database.GetDB()
+	if err != nil || db == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "database unavailable"})
+		return
+	}
+
+	account := &webAuthnAccount{userID: session.UserID, login: session.UserLogin, isCustomer: customer}
+	if !customer {
+		account.login = session.Username
+	}
+	challenge, ok := auth.GetWebAuthnChallengeStore().Take("login:" + token)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "error": "security key request expired, please try again"})
+		return
+	}
+
+	svc := webAuthnLoginService(db, session)
+	creds, err := svc.Credentials()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "error": "failed to load security keys"})
+		return
+	}
+	clientData, err1 := auth.DecodeWebAuthnBase64(req.ClientDataJSON)
+	authData, err2 := auth.DecodeWebAuthnBase64(req.AuthenticatorData)
+	signature, err3 := auth.DecodeWebAuthnBase64(req.Signature)
+	cred := auth.FindWebAuthnCredential(creds, req.ID)
+	if err1 != nil || err2 != nil || err3 != nil || cred == nil {
+		webAuthnLoginFailed(c, account, token, "unknown credential or invalid encoding")
+		return
+	}
+	signCount, err := webAuthnRelyingParty(c).VerifyAssertion(challenge, cred, clientData, authData, signature)
+	if err != nil {
+		webAuthnLoginFailed(c, account, token, err.Error())
+		return
+	}
+	if err := svc.RecordUse(cred.ID, signCount); err != nil {
+		log.Printf("Failed to record security key use: %v", err)
+	}
+
+	account.audit(c, auth.AuditWebAuthnVerifySuccess, true, cred.Name)
+	auth.GetTOTPSessionManager().InvalidateSession(token)
+	c.SetCookie(webAuthnLoginCookie(customer), "", -1, "/", "", false, true)
+
+	if customer {
+		completeCustomerTwoFactorLogin(c, db, session.UserLogin)
+		return
+	}
+	completeAgentTwoFactorLogin(c, shared.GetJWTManager(), db, session.UserID, session.Username)
+}
+
+func webAuthnLoginFailed(c *gin.Context, account *webAuthnAccount, token, reason string) {
+	account.audit(c, auth.AuditWebAuthnVerifyFailed, false, reason)
+	sessionMgr := auth.GetTOTPSessionManager()
+	remaining := sessionMgr.RecordFailedAttempt(token)
+	if remaining <= 0 {
+		sessionMgr.InvalidateSession(token)
+		c.SetCookie(webAuthnLoginCookie(account.isCustomer), "", -1, "/", "", false, true)
+		c.JSON(http.StatusUnauthorized, gin.H{"success": false, "error": "too many failed attempts, please login again"})
+		return
+	}
+	c.JSON(http.StatusUnauthorized, gin.H{
+		"success":            false,
+		"error":              "security key verification failed",
+		"attempts_remaining": remaining,
+	})
+}
@@ -0,0 +1,73 @@
+package api
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebAuthnRelyingPartyDefaults(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "http://helpdesk.example.com:8080/login/2fa", nil)
+	rp := webAuthnRelyingParty(c)
+	assert.Equal(t, "helpdesk.example.com", rp.ID)
+	assert.Equal(t, []string{"http://helpdesk.example.com:8080"}, rp.Origins)
+	assert.NotEmpty(t, rp.Name)
+
+	c.Request = httptest.NewRequest(http.MethodGet, "https://helpdesk.example.com/login/2fa", nil)
+	c.Request.TLS = &tls.ConnectionState{}
+	assert.Equal(t, []string{"https://helpdesk.example.com"}, webAuthnRelyingParty(c).Origins)
+
+	c.Request = httptest.NewRequest(http.MethodGet, "http://helpdesk.example.com/login/2fa", nil)
+	c.Request.Header.Set("X-Forwarded-Proto", "https")
+	assert.Equal(t, []string{"https://helpdesk.example.com"}, webAuthnRelyingParty(c).Origins)
+}
+
+func TestWebAuthnLoginRequiresPendingSession(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/api/auth/2fa/webauthn/begin", handleWebAuthnLoginBegin)
+	router.POST("/api/auth/2fa/webauthn/finish", handleWebAuthnLoginFinish)
+	router.POST("/api/auth/customer/2fa/webauthn/begin", handleCustomerWebAuthnLoginBegin)
+
+	for _, tc := range []struct {
+		path   string
+		cookie *http.Cookie
+	}{
+		{path: "/api/auth/2fa/webauthn/begin"},
+		{path: "/api/auth/2fa/webauthn/finish", cookie: &http.Cookie{Name: "2fa_pending", Value: "bogus"}},
+		{path: "/api/auth/customer/2fa/webauthn/begin", cookie: &http.Cookie{Name: "customer_2fa_pending", Value: "bogus"}},
+	} {
+		t.Run(tc.path, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tc.path, strings.NewReader(`{}`))
+			req.Header.Set("Content-Type", "application/json")
+			if tc.cookie != nil {
+				req.AddCookie(tc.cookie)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusUnauthorized, w.Code)
+			assert.Contains(t, w.Body.String(), `"success":false`)
+		})
+	}
+}
+
+func TestWebAuthnKeyManagementRequiresLogin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/api/preferences/2fa/webauthn", handleWebAuthnCredentials)
+	router.GET("/customer/api/preferences/2fa/webauthn", handleCustomerWebAuthnCredentials)
+
+	for _, path := range []string{"/api/preferences/2fa/webauthn", "/customer/api/preferences/2fa/webauthn"} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Equal(t, http.StatusUnauthorized, w.Code, path)
+	}
+}
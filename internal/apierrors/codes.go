@@ -12,6 +12,8 @@ const (
 	CodeInvalidToken = "core:invalid_token"
 	CodeTokenExpired = "core:token_expired"
 	CodeTokenRevoked = "core:token_revoked"
+	CodeIPNotAllowed = "core:ip_not_allowed"
+	CodeCSRFInvalid  = "core:csrf_invalid"
 
 	// Request errors
 	CodeInvalidRequest    = "core:invalid_request"
@@ -25,6 +27,10 @@ const (
 	CodeTokenNotFound = "core:token_not_found"
 	CodeConflict      = "core:conflict"
 
+	// Idempotency keys
+	CodeIdempotencyInProgress = "core:idempotency_in_progress"
+	CodeIdempotencyKeyReused  = "core:idempotency_key_reused"
+
 	// Rate limiting
 	CodeRateLimited = "core:rate_limited"
 
@@ -41,6 +47,8 @@ var coreErrors = []ErrorCode{
 	{Code: CodeInvalidToken, Message: "Invalid or malformed token", HTTPStatus: http.StatusUnauthorized},
 	{Code: CodeTokenExpired, Message: "Token has expired", HTTPStatus: http.StatusUnauthorized},
 	{Code: CodeTokenRevoked, Message: "Token has been revoked", HTTPStatus: http.StatusUnauthorized},
+	{Code: CodeIPNotAllowed, Message: "Token is not allowed from this IP address", HTTPStatus: http.StatusForbidden},
+	{Code: CodeCSRFInvalid, Message: "Missing or invalid CSRF token", HTTPStatus: http.StatusForbidden},
 
 	// Request errors
 	{Code: CodeInvalidRequest, Message: "Invalid request body", HTTPStatus: http.StatusBadRequest},
@@ -54,6 +62,10 @@ var coreErrors = []ErrorCode{
 	{Code: CodeTokenNotFound, Message: "Token not found", HTTPStatus: http.StatusNotFound},
 	{Code: CodeConflict, Message: "Resource conflict", HTTPStatus: http.StatusConflict},
 
+	// Idempotency keys
+	{Code: CodeIdempotencyInProgress, Message: "A request with this Idempotency-Key is still being processed", HTTPStatus: http.StatusConflict},
+	{Code: CodeIdempotencyKeyReused, Message: "Idempotency-Key was already used for a different request", HTTPStatus: http.StatusUnprocessableEntity},
+
 	// Rate limiting
 	{Code: CodeRateLimited, Message: "Too many requests", HTTPStatus: http.StatusTooManyRequests},
 
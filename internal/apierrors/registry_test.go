@@ -54,6 +54,7 @@ func TestRegistry_HTTPStatus(t *testing.T) {
 		{CodeInvalidRequest, http.StatusBadRequest},
 		{CodeInternalError, http.StatusInternalServerError},
 		{CodeRateLimited, http.StatusTooManyRequests},
+		{CodeIPNotAllowed, http.StatusForbidden},
 	}
 
 	for _, tt := range tests {
@@ -20,6 +20,7 @@ type Claims struct {
 	Role     string `json:"role"`
 	IsAdmin  bool   `json:"is_admin,omitempty"` // User is in admin group (for nav display)
 	TenantID uint   `json:"tenant_id,omitempty"`
+	MFA      bool   `json:"mfa,omitempty"` // Session completed a second factor (TOTP or WebAuthn)
 	jwt.RegisteredClaims
 }
 
@@ -46,6 +47,16 @@ func (m *JWTManager) GenerateTokenWithAdmin(userID uint, email, role string, isA
 
 // GenerateTokenWithLogin creates a JWT with explicit login and email values.
 func (m *JWTManager) GenerateTokenWithLogin(userID uint, login, email, role string, isAdmin bool, tenantID uint) (string, error) {
+	return m.generateToken(userID, login, email, role, isAdmin, tenantID, false)
+}
+
+// GenerateTwoFactorToken creates a JWT for a session that has completed a
+// second factor. The mfa claim satisfies the two-factor policy middleware.
+func (m *JWTManager) GenerateTwoFactorToken(userID uint, login, email, role string, isAdmin bool, tenantID uint) (string, error) {
+	return m.generateToken(userID, login, email, role, isAdmin, tenantID, true)
+}
+
+func (m *JWTManager) generateToken(userID uint, login, email, role string, isAdmin bool, tenantID uint, mfa bool) (string, error) {
 	claims := Claims{
 		UserID:   userID,
 		Email:    email,
@@ -53,6 +64,7 @@ func (m *JWTManager) GenerateTokenWithLogin(userID uint, login, email, role stri
 		Role:     role,
 		IsAdmin:  isAdmin,
 		TenantID: tenantID,
+		MFA:      mfa,
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(m.tokenDuration)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
@@ -72,6 +72,23 @@ func TestJWTManager(t *testing.T) {
 		assert.Error(t, err)
 	})
 
+	t.Run("GenerateTwoFactorToken sets the mfa claim", func(t *testing.T) {
+		token, err := jwtManager.GenerateTwoFactorToken(4, "agent1", "agent1@example.com", "Agent", true, 1)
+		require.NoError(t, err)
+
+		claims, err := jwtManager.ValidateToken(token)
+		require.NoError(t, err)
+		assert.True(t, claims.MFA)
+		assert.True(t, claims.IsAdmin)
+		assert.Equal(t, "agent1", claims.Login)
+
+		plain, err := jwtManager.GenerateTokenWithLogin(4, "agent1", "agent1@example.com", "Agent", true, 1)
+		require.NoError(t, err)
+		claims, err = jwtManager.ValidateToken(plain)
+		require.NoError(t, err)
+		assert.False(t, claims.MFA)
+	})
+
 	t.Run("GenerateRefreshToken creates valid refresh token", func(t *testing.T) {
 		userID := uint(3)
 		email := "refresh@example.com"
@@ -0,0 +1,190 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testOIDCProvider is a fake identity provider serving discovery, JWKS and a
+// token endpoint that returns whatever ID token the test prepared.
+type testOIDCProvider struct {
+	server   *httptest.Server
+	key      *rsa.PrivateKey
+	idToken  string
+	verifier string // code_verifier seen by the token endpoint
+}
+
+func newTestOIDCProvider(t *testing.T) *testOIDCProvider {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	p := &testOIDCProvider{key: key}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(OIDCDiscovery{
+			Issuer:                p.server.URL,
+			AuthorizationEndpoint: p.server.URL + "/authorize",
+			TokenEndpoint:         p.server.URL + "/token",
+			JWKSURI:               p.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA", "kid": "k1", "use": "sig",
+			"n": base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e": base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if r.PostFormValue("code") != "good-code" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		p.verifier = r.PostFormValue("code_verifier")
+		_ = json.NewEncoder(w).Encode(map[string]string{"access_token": "x", "id_token": p.idToken})
+	})
+	p.server = httptest.NewServer(mux)
+	t.Cleanup(p.server.Close)
+	return p
+}
+
+func (p *testOIDCProvider) sign(t *testing.T, claims jwt.MapClaims) string {
+	t.Helper()
+	tok := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	tok.Header["kid"] = "k1"
+	s, err := tok.SignedString(p.key)
+	require.NoError(t, err)
+	return s
+}
+
+func (p *testOIDCProvider) claims(nonce string) jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss":    p.server.URL,
+		"aud":    "goatflow",
+		"sub":    "user-1",
+		"email":  "jane@example.com",
+		"groups": []string{"support", "admins"},
+		"nonce":  nonce,
+		"iat":    time.Now().Unix(),
+		"exp":    time.Now().Add(time.Minute).Unix(),
+	}
+}
+
+func TestOIDCClientAuthCodeFlow(t *testing.T) {
+	p := newTestOIDCProvider(t)
+	client := NewOIDCClient(OIDCConfig{Issuer: p.server.URL + "/", ClientID: "goatflow", RedirectURL: "https://helpdesk.example.com/auth/sso/1/callback"}, p.server.Client())
+	ctx := context.Background()
+
+	authURL, err := client.AuthCodeURL(ctx, "state-1", "nonce-1", "verifier-1")
+	require.NoError(t, err)
+	u, err := url.Parse(authURL)
+	require.NoError(t, err)
+	q := u.Query()
+	assert.Equal(t, "/authorize", u.Path)
+	assert.Equal(t, "code", q.Get("response_type"))
+	assert.Equal(t, "openid profile email", q.Get("scope"))
+	assert.Equal(t, "state-1", q.Get("state"))
+	assert.Equal(t, "nonce-1", q.Get("nonce"))
+	challenge := sha256.Sum256([]byte("verifier-1"))
+	assert.Equal(t, base64.RawURLEncoding.EncodeToString(challenge[:]), q.Get("code_challenge"))
+	assert.Equal(t, "S256", q.Get("code_challenge_method"))
+
+	p.idToken = p.sign(t, p.claims("nonce-1"))
+	claims, err := client.Exchange(ctx, "good-code", "verifier-1", "nonce-1")
+	require.NoError(t, err)
+	assert.Equal(t, "verifier-1", p.verifier)
+	assert.Equal(t, "jane@example.com", claims["email"])
+	assert.Equal(t, []string{"support", "admins"}, ClaimValues(claims["groups"]))
+
+	_, err = client.Exchange(ctx, "bad-code", "verifier-1", "nonce-1")
+	assert.Error(t, err)
+}
+
+func TestOIDCClientRejectsInvalidIDTokens(t *testing.T) {
+	p := newTestOIDCProvider(t)
+	client := NewOIDCClient(OIDCConfig{Issuer: p.server.URL, ClientID: "goatflow"}, p.server.Client())
+	ctx := context.Background()
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		mutate func(jwt.MapClaims)
+		token  func(jwt.MapClaims) string
+	}{
+		{name: "wrong nonce", mutate: func(c jwt.MapClaims) { c["nonce"] = "other" }},
+		{name: "wrong audience", mutate: func(c jwt.MapClaims) { c["aud"] = "someone-else" }},
+		{name: "wrong issuer", mutate: func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" }},
+		{name: "expired", mutate: func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Hour).Unix() }},
+		{name: "foreign azp", mutate: func(c jwt.MapClaims) { c["aud"] = []string{"goatflow", "other"}; c["azp"] = "other" }},
+		{name: "unknown signer", token: func(c jwt.MapClaims) string {
+			tok := jwt.NewWithClaims(jwt.SigningMethodRS256, c)
+			tok.Header["kid"] = "k1"
+			s, _ := tok.SignedString(otherKey)
+			return s
+		}},
+		{name: "unsigned", token: func(c jwt.MapClaims) string {
+			s, _ := jwt.NewWithClaims(jwt.SigningMethodNone, c).SignedString(jwt.UnsafeAllowNoneSignatureType)
+			return s
+		}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			claims := p.claims("nonce-1")
+			if tc.mutate != nil {
+				tc.mutate(claims)
+			}
+			raw := ""
+			if tc.token != nil {
+				raw = tc.token(claims)
+			} else {
+				raw = p.sign(t, claims)
+			}
+			_, err := client.VerifyIDToken(ctx, raw, "nonce-1")
+			assert.Error(t, err)
+		})
+	}
+
+	_, err = client.VerifyIDToken(ctx, p.sign(t, p.claims("nonce-1")), "nonce-1")
+	assert.NoError(t, err)
+}
+
+func TestOIDCDiscoveryIssuerMismatch(t *testing.T) {
+	p := newTestOIDCProvider(t)
+	client := NewOIDCClient(OIDCConfig{Issuer: p.server.URL + "/tenant", ClientID: "goatflow"}, p.server.Client())
+	_, err := client.Discover(context.Background())
+	assert.Error(t, err)
+}
+
+func TestParseJWKRejectsWeakAndEncryptionKeys(t *testing.T) {
+	_, _, err := ParseJWK([]byte(`{"kty":"RSA","kid":"small","n":"AQAB","e":"AQAB"}`))
+	assert.Error(t, err)
+	_, _, err = ParseJWK([]byte(`{"kty":"RSA","kid":"enc","use":"enc","n":"AQAB","e":"AQAB"}`))
+	assert.Error(t, err)
+	_, _, err = ParseJWK([]byte(`{"kty":"oct","kid":"hmac","k":"c2VjcmV0"}`))
+	assert.Error(t, err)
+}
+
+func TestClaimValues(t *testing.T) {
+	assert.Nil(t, ClaimValues(nil))
+	assert.Equal(t, []string{"a"}, ClaimValues("a"))
+	assert.Equal(t, []string{"a", "b"}, ClaimValues([]interface{}{"a", "b"}))
+	assert.Equal(t, []string{"42"}, ClaimValues(float64(42)))
+	assert.Equal(t, []string{"true"}, ClaimValues(true))
+}
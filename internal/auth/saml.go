@@ -0,0 +1,268 @@
+package auth
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	samlProtocolNS  = "urn:oasis:names:tc:SAML:2.0:protocol"
+	samlAssertionNS = "urn:oasis:names:tc:SAML:2.0:assertion"
+	samlStatusOK    = "urn:oasis:names:tc:SAML:2.0:status:Success"
+	samlBearer      = "urn:oasis:names:tc:SAML:2.0:cm:bearer"
+	samlHTTPPost    = "urn:oasis:names:tc:SAML:2.0:bindings:HTTP-POST"
+	samlNameIDAny   = "urn:oasis:names:tc:SAML:1.1:nameid-format:unspecified"
+)
+
+// samlClockSkew tolerates clock drift between GoatFlow and the IdP.
+const samlClockSkew = 2 * time.Minute
+
+// SAMLConfig describes a SAML 2.0 identity provider and GoatFlow's service
+// provider endpoints for it.
+type SAMLConfig struct {
+	IdPSSOURL      string // HTTP-Redirect single sign-on endpoint
+	IdPEntityID    string
+	IdPCertificate string // PEM or base64 DER signing certificate(s)
+	SPEntityID     string
+	ACSURL         string // Assertion consumer service (HTTP-POST)
+}
+
+// SAMLAssertion is the verified outcome of a SAML login.
+type SAMLAssertion struct {
+	NameID       string
+	SessionIndex string
+	// Attributes are keyed by attribute Name and, when present, FriendlyName.
+	Attributes map[string][]string
+}
+
+// SAMLServiceProvider runs SP-initiated SAML logins: it builds AuthnRequests
+// for the HTTP-Redirect binding and verifies responses posted to the ACS.
+// Encrypted assertions are not supported.
+type SAMLServiceProvider struct {
+	cfg   SAMLConfig
+	certs []*x509.Certificate
+	now   func() time.Time
+}
+
+// NewSAMLServiceProvider validates cfg and parses the IdP certificate.
+func NewSAMLServiceProvider(cfg SAMLConfig) (*SAMLServiceProvider, error) {
+	if cfg.IdPSSOURL == "" || cfg.IdPEntityID == "" || cfg.SPEntityID == "" || cfg.ACSURL == "" {
+		return nil, errors.New("saml: IdP SSO URL, IdP entity ID, SP entity ID and ACS URL are required")
+	}
+	certs, err := ParseCertificates(cfg.IdPCertificate)
+	if err != nil {
+		return nil, fmt.Errorf("saml: IdP certificate: %w", err)
+	}
+	return &SAMLServiceProvider{cfg: cfg, certs: certs, now: time.Now}, nil
+}
+
+// AuthnRequestURL returns the IdP URL carrying a new AuthnRequest and the
+// request ID, which the response must answer.
+func (sp *SAMLServiceProvider) AuthnRequestURL(relayState string) (string, string, error) {
+	idBytes := make([]byte, 20)
+	if _, err := rand.Read(idBytes); err != nil {
+		return "", "", err
+	}
+	requestID := "_" + hex.EncodeToString(idBytes)
+
+	var req bytes.Buffer
+	fmt.Fprintf(&req, `<samlp:AuthnRequest xmlns:samlp="%s" xmlns:saml="%s" ID="%s" Version="2.0" IssueInstant="%s" Destination="%s" AssertionConsumerServiceURL="%s" ProtocolBinding="%s">`,
+		samlProtocolNS, samlAssertionNS, requestID, sp.now().UTC().Format(time.RFC3339),
+		xmlAttrEscape(sp.cfg.IdPSSOURL), xmlAttrEscape(sp.cfg.ACSURL), samlHTTPPost)
+	fmt.Fprintf(&req, `<saml:Issuer>%s</saml:Issuer><samlp:NameIDPolicy Format="%s" AllowCreate="true"/></samlp:AuthnRequest>`,
+		xmlAttrEscape(sp.cfg.SPEntityID), samlNameIDAny)
+
+	var deflated bytes.Buffer
+	w, err := flate.NewWriter(&deflated, flate.BestCompression)
+	if err != nil {
+		return "", "", err
+	}
+	if _, err := w.Write(req.Bytes()); err != nil {
+		return "", "", err
+	}
+	if err := w.Close(); err != nil {
+		return "", "", err
+	}
+
+	q := url.Values{}
+	q.Set("SAMLRequest", base64.StdEncoding.EncodeToString(deflated.Bytes()))
+	if relayState != "" {
+		q.Set("RelayState", relayState)
+	}
+	sep := "?"
+	if strings.Contains(sp.cfg.IdPSSOURL, "?") {
+		sep = "&"
+	}
+	return sp.cfg.IdPSSOURL + sep + q.Encode(), requestID, nil
+}
+
+// ParseResponse verifies a base64 SAMLResponse from the HTTP-POST binding.
+// Either the response or its single assertion must carry a valid signature
+// from the IdP; the response must answer requestID and be addressed to this
+// service provider.
+func (sp *SAMLServiceProvider) ParseResponse(encoded, requestID string) (*SAMLAssertion, error) {
+	raw, err := base64.StdEncoding.DecodeString(stripXMLSpace(encoded))
+	if err != nil {
+		return nil, errors.New("saml: response is not base64")
+	}
+	resp, err := parseXMLDocument(bytes.NewReader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("saml: %w", err)
+	}
+	if !resp.is(samlProtocolNS, "Response") {
+		return nil, errors.New("saml: document is not a Response")
+	}
+
+	if status := resp.child(samlProtocolNS, "Status").child(samlProtocolNS, "StatusCode").attr("Value"); status != samlStatusOK {
+		return nil, fmt.Errorf("saml: IdP returned status %q", status)
+	}
+	if dest := resp.attr("Destination"); dest != "" && dest != sp.cfg.ACSURL {
+		return nil, errors.New("saml: response destination mismatch")
+	}
+	if requestID == "" || resp.attr("InResponseTo") != requestID {
+		return nil, errors.New("saml: response does not answer the pending request")
+	}
+	if issuer := resp.child(samlAssertionNS, "Issuer"); issuer != nil && strings.TrimSpace(issuer.textContent()) != sp.cfg.IdPEntityID {
+		return nil, errors.New("saml: response issuer mismatch")
+	}
+	if resp.child(samlAssertionNS, "EncryptedAssertion") != nil {
+		return nil, errors.New("saml: encrypted assertions are not supported")
+	}
+
+	assertions := resp.childrenNamed(samlAssertionNS, "Assertion")
+	if len(assertions) != 1 {
+		return nil, errors.New("saml: response must contain exactly one assertion")
+	}
+	assertion := assertions[0]
+
+	respErr := verifyEnvelopedSignature(resp, sp.certs)
+	if respErr != nil && respErr != ErrXMLUnsigned {
+		return nil, fmt.Errorf("saml: response signature: %w", respErr)
+	}
+	if err := verifyEnvelopedSignature(assertion, sp.certs); err != nil {
+		if err != ErrXMLUnsigned || respErr != nil {
+			return nil, fmt.Errorf("saml: assertion signature: %w", err)
+		}
+	}
+
+	return sp.readAssertion(assertion, requestID)
+}
+
+// readAssertion validates the conditions of a verified assertion and
+// extracts the subject and attributes.
+func (sp *SAMLServiceProvider) readAssertion(a *xmlNode, requestID string) (*SAMLAssertion, error) {
+	now := sp.now()
+	if strings.TrimSpace(a.child(samlAssertionNS, "Issuer").textContent()) != sp.cfg.IdPEntityID {
+		return nil, errors.New("saml: assertion issuer mismatch")
+	}
+
+	subject := a.child(samlAssertionNS, "Subject")
+	if subject == nil {
+		return nil, errors.New("saml: assertion has no subject")
+	}
+	confirmed := false
+	for _, sc := range subject.childrenNamed(samlAssertionNS, "SubjectConfirmation") {
+		if sc.attr("Method") != samlBearer {
+			continue
+		}
+		data := sc.child(samlAssertionNS, "SubjectConfirmationData")
+		if data == nil || data.attr("Recipient") != sp.cfg.ACSURL {
+			continue
+		}
+		if irt := data.attr("InResponseTo"); irt != "" && irt != requestID {
+			continue
+		}
+		if notOnOrAfter, err := parseSAMLTime(data.attr("NotOnOrAfter")); err != nil || !now.Before(notOnOrAfter.Add(samlClockSkew)) {
+			continue
+		}
+		confirmed = true
+		break
+	}
+	if !confirmed {
+		return nil, errors.New("saml: no valid bearer subject confirmation")
+	}
+
+	cond := a.child(samlAssertionNS, "Conditions")
+	if cond == nil {
+		return nil, errors.New("saml: assertion has no conditions")
+	}
+	if v := cond.attr("NotBefore"); v != "" {
+		t, err := parseSAMLTime(v)
+		if err != nil || now.Add(samlClockSkew).Before(t) {
+			return nil, errors.New("saml: assertion is not yet valid")
+		}
+	}
+	if v := cond.attr("NotOnOrAfter"); v != "" {
+		t, err := parseSAMLTime(v)
+		if err != nil || !now.Before(t.Add(samlClockSkew)) {
+			return nil, errors.New("saml: assertion has expired")
+		}
+	}
+	audienceOK := false
+	for _, ar := range cond.childrenNamed(samlAssertionNS, "AudienceRestriction") {
+		for _, aud := range ar.childrenNamed(samlAssertionNS, "Audience") {
+			if strings.TrimSpace(aud.textContent()) == sp.cfg.SPEntityID {
+				audienceOK = true
+			}
+		}
+	}
+	if !audienceOK {
+		return nil, errors.New("saml: assertion is not intended for this service provider")
+	}
+
+	out := &SAMLAssertion{
+		NameID:     strings.TrimSpace(subject.child(samlAssertionNS, "NameID").textContent()),
+		Attributes: make(map[string][]string),
+	}
+	if stmt := a.child(samlAssertionNS, "AuthnStatement"); stmt != nil {
+		out.SessionIndex = stmt.attr("SessionIndex")
+	}
+	for _, stmt := range a.childrenNamed(samlAssertionNS, "AttributeStatement") {
+		for _, attr := range stmt.childrenNamed(samlAssertionNS, "Attribute") {
+			var values []string
+			for _, v := range attr.childrenNamed(samlAssertionNS, "AttributeValue") {
+				values = append(values, strings.TrimSpace(v.textContent()))
+			}
+			for _, key := range []string{attr.attr("Name"), attr.attr("FriendlyName")} {
+				if key != "" {
+					out.Attributes[key] = append(out.Attributes[key], values...)
+				}
+			}
+		}
+	}
+	return out, nil
+}
+
+// Metadata returns the service provider metadata document to register with
+// the IdP.
+func (sp *SAMLServiceProvider) Metadata() []byte {
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	fmt.Fprintf(&buf, `<md:EntityDescriptor xmlns:md="urn:oasis:names:tc:SAML:2.0:metadata" entityID="%s">`, xmlAttrEscape(sp.cfg.SPEntityID))
+	fmt.Fprintf(&buf, `<md:SPSSODescriptor AuthnRequestsSigned="false" WantAssertionsSigned="true" protocolSupportEnumeration="%s">`, samlProtocolNS)
+	fmt.Fprintf(&buf, `<md:NameIDFormat>%s</md:NameIDFormat>`, samlNameIDAny)
+	fmt.Fprintf(&buf, `<md:AssertionConsumerService Binding="%s" Location="%s" index="0" isDefault="true"/>`, samlHTTPPost, xmlAttrEscape(sp.cfg.ACSURL))
+	buf.WriteString(`</md:SPSSODescriptor></md:EntityDescriptor>`)
+	buf.WriteByte('\n')
+	return buf.Bytes()
+}
+
+func parseSAMLTime(v string) (time.Time, error) {
+	return time.Parse(time.RFC3339Nano, strings.TrimSpace(v))
+}
+
+func xmlAttrEscape(s string) string {
+	var buf bytes.Buffer
+	_ = xml.EscapeText(&buf, []byte(s)) //nolint:errcheck // Writing to a bytes.Buffer cannot fail
+	return buf.String()
+}
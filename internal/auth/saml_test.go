@@ -0,0 +1,259 @@
+package auth
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testIdPEntityID = "https://idp.example.com/metadata"
+	testSPEntityID  = "https://helpdesk.example.com/auth/sso/3/metadata"
+	testACSURL      = "https://helpdesk.example.com/auth/sso/3/acs"
+	testRequestID   = "_req123"
+)
+
+func newTestIdPKey(t *testing.T) (*rsa.PrivateKey, string) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "idp.example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	return key, string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+}
+
+// testSAMLResponse returns a response with <!--sig:ID--> markers where
+// signTestXML inserts signatures.
+func testSAMLResponse(nameID string) string {
+	now := time.Now().UTC()
+	later := now.Add(5 * time.Minute).Format(time.RFC3339)
+	return `<samlp:Response xmlns:samlp="urn:oasis:names:tc:SAML:2.0:protocol" xmlns:saml="urn:oasis:names:tc:SAML:2.0:assertion" ID="_resp1" Version="2.0" IssueInstant="` + now.Format(time.RFC3339) + `" Destination="` + testACSURL + `" InResponseTo="` + testRequestID + `">` +
+		`<saml:Issuer>` + testIdPEntityID + `</saml:Issuer><!--sig:_resp1-->` +
+		`<samlp:Status><samlp:StatusCode Value="urn:oasis:names:tc:SAML:2.0:status:Success"/></samlp:Status>` +
+		`<saml:Assertion ID="_assert1" Version="2.0" IssueInstant="` + now.Format(time.RFC3339) + `">
+  <saml:Issuer>` + testIdPEntityID + `</saml:Issuer><!--sig:_assert1-->
+  <saml:Subject>
+    <saml:NameID Format="urn:oasis:names:tc:SAML:1.1:nameid-format:emailAddress">` + nameID + `</saml:NameID>
+    <saml:SubjectConfirmation Method="urn:oasis:names:tc:SAML:2.0:cm:bearer">
+      <saml:SubjectConfirmationData NotOnOrAfter="` + later + `" Recipient="` + testACSURL + `" InResponseTo="` + testRequestID + `"/>
+    </saml:SubjectConfirmation>
+  </saml:Subject>
+  <saml:Conditions NotBefore="` + now.Add(-time.Minute).Format(time.RFC3339) + `" NotOnOrAfter="` + later + `">
+    <saml:AudienceRestriction><saml:Audience>` + testSPEntityID + `</saml:Audience></saml:AudienceRestriction>
+  </saml:Conditions>
+  <saml:AuthnStatement AuthnInstant="` + now.Format(time.RFC3339) + `" SessionIndex="_sess1"/>
+  <saml:AttributeStatement>
+    <saml:Attribute Name="http://schemas.xmlsoap.org/claims/Group" FriendlyName="groups">
+      <saml:AttributeValue xmlns:xs="http://www.w3.org/2001/XMLSchema" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xsi:type="xs:string">support</saml:AttributeValue>
+      <saml:AttributeValue>admins</saml:AttributeValue>
+    </saml:Attribute>
+    <saml:Attribute Name="givenName"><saml:AttributeValue>Jane &amp; Co</saml:AttributeValue></saml:Attribute>
+  </saml:AttributeStatement>
+</saml:Assertion></samlp:Response>`
+}
+
+func findTestXMLByID(n *xmlNode, id string) *xmlNode {
+	if n.kind == xmlElementNode && n.attr("ID") == id {
+		return n
+	}
+	for _, c := range n.children {
+		if found := findTestXMLByID(c, id); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// signTestXML signs the element with the given ID the way IdPs do: an
+// enveloped signature with exclusive canonicalization and RSA-SHA256.
+func signTestXML(t *testing.T, doc, id string, key *rsa.PrivateKey) string {
+	t.Helper()
+	root, err := parseXMLDocument(strings.NewReader(doc))
+	require.NoError(t, err)
+	el := findTestXMLByID(root, id)
+	require.NotNil(t, el)
+	digest := sha256.Sum256(canonicalizeExclusive(el, nil, []string{"xs"}))
+
+	signedInfo := `<ds:SignedInfo><ds:CanonicalizationMethod Algorithm="` + xmlExcC14N + `"/>` +
+		`<ds:SignatureMethod Algorithm="` + xmlDSigRSASHA256 + `"/>` +
+		`<ds:Reference URI="#` + id + `"><ds:Transforms>` +
+		`<ds:Transform Algorithm="` + xmlEnvelopedSig + `"/>` +
+		`<ds:Transform Algorithm="` + xmlExcC14N + `"><ec:InclusiveNamespaces xmlns:ec="` + xmlExcC14N + `" PrefixList="xs"/></ds:Transform>` +
+		`</ds:Transforms><ds:DigestMethod Algorithm="` + xmlEncSHA256 + `"/>` +
+		`<ds:DigestValue>` + base64.StdEncoding.EncodeToString(digest[:]) + `</ds:DigestValue></ds:Reference></ds:SignedInfo>`
+	signature := `<ds:Signature xmlns:ds="` + xmlDSigNS + `">` + signedInfo + `<ds:SignatureValue>SIGVALUE</ds:SignatureValue></ds:Signature>`
+	doc = strings.Replace(doc, "<!--sig:"+id+"-->", signature, 1)
+
+	root, err = parseXMLDocument(strings.NewReader(doc))
+	require.NoError(t, err)
+	si := findTestXMLByID(root, id).child(xmlDSigNS, "Signature").child(xmlDSigNS, "SignedInfo")
+	hashed := sha256.Sum256(canonicalizeExclusive(si, nil, nil))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hashed[:])
+	require.NoError(t, err)
+	return strings.Replace(doc, "SIGVALUE", base64.StdEncoding.EncodeToString(sig), 1)
+}
+
+func newTestSAMLSP(t *testing.T, cert string) *SAMLServiceProvider {
+	t.Helper()
+	sp, err := NewSAMLServiceProvider(SAMLConfig{
+		IdPSSOURL:      "https://idp.example.com/sso?tenant=1",
+		IdPEntityID:    testIdPEntityID,
+		IdPCertificate: cert,
+		SPEntityID:     testSPEntityID,
+		ACSURL:         testACSURL,
+	})
+	require.NoError(t, err)
+	return sp
+}
+
+func encodeTestSAML(doc string) string {
+	return base64.StdEncoding.EncodeToString([]byte(doc))
+}
+
+func TestSAMLParseSignedAssertion(t *testing.T) {
+	key, cert := newTestIdPKey(t)
+	sp := newTestSAMLSP(t, cert)
+
+	doc := signTestXML(t, testSAMLResponse("jane@example.com"), "_assert1", key)
+	a, err := sp.ParseResponse(encodeTestSAML(doc), testRequestID)
+	require.NoError(t, err)
+	assert.Equal(t, "jane@example.com", a.NameID)
+	assert.Equal(t, "_sess1", a.SessionIndex)
+	assert.Equal(t, []string{"support", "admins"}, a.Attributes["groups"])
+	assert.Equal(t, []string{"support", "admins"}, a.Attributes["http://schemas.xmlsoap.org/claims/Group"])
+	assert.Equal(t, []string{"Jane & Co"}, a.Attributes["givenName"])
+}
+
+func TestSAMLParseSignedResponse(t *testing.T) {
+	key, cert := newTestIdPKey(t)
+	sp := newTestSAMLSP(t, cert)
+
+	doc := signTestXML(t, testSAMLResponse("jane@example.com"), "_resp1", key)
+	a, err := sp.ParseResponse(encodeTestSAML(doc), testRequestID)
+	require.NoError(t, err)
+	assert.Equal(t, "jane@example.com", a.NameID)
+
+	// Both signed, as some IdPs do.
+	doc = signTestXML(t, signTestXML(t, testSAMLResponse("jane@example.com"), "_assert1", key), "_resp1", key)
+	_, err = sp.ParseResponse(encodeTestSAML(doc), testRequestID)
+	require.NoError(t, err)
+}
+
+func TestSAMLRejectsForgedResponses(t *testing.T) {
+	key, cert := newTestIdPKey(t)
+	otherKey, _ := newTestIdPKey(t)
+	sp := newTestSAMLSP(t, cert)
+	signed := signTestXML(t, testSAMLResponse("jane@example.com"), "_assert1", key)
+
+	tests := []struct {
+		name      string
+		doc       string
+		requestID string
+	}{
+		{name: "unsigned", doc: testSAMLResponse("jane@example.com")},
+		{name: "tampered subject", doc: strings.Replace(signed, "jane@example.com", "admin@example.com", 1)},
+		{name: "foreign key", doc: signTestXML(t, testSAMLResponse("jane@example.com"), "_assert1", otherKey)},
+		{name: "unsolicited", doc: signed, requestID: "_other"},
+		{name: "wrapped assertion", doc: strings.Replace(signed, "</samlp:Response>",
+			strings.Replace(testSAMLResponse("admin@example.com")[strings.Index(testSAMLResponse(""), "<saml:Assertion"):], "_assert1", "_evil", 1), 1)},
+		{name: "wrong audience", doc: signTestXML(t, strings.Replace(testSAMLResponse("jane@example.com"), testSPEntityID, "https://other.example.com", 1), "_assert1", key)},
+		{name: "wrong recipient", doc: signTestXML(t, strings.Replace(testSAMLResponse("jane@example.com"), `Recipient="`+testACSURL, `Recipient="https://other.example.com/acs`, 1), "_assert1", key)},
+		{name: "failed status", doc: strings.Replace(signed, "status:Success", "status:Requester", 1)},
+		{name: "doctype", doc: `<!DOCTYPE x [<!ENTITY e "x">]>` + signed},
+		{name: "encrypted", doc: strings.Replace(signed, "<samlp:Status>", "<saml:EncryptedAssertion/><samlp:Status>", 1)},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			requestID := tc.requestID
+			if requestID == "" {
+				requestID = testRequestID
+			}
+			_, err := sp.ParseResponse(encodeTestSAML(tc.doc), requestID)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestSAMLRejectsExpiredAssertion(t *testing.T) {
+	key, cert := newTestIdPKey(t)
+	sp := newTestSAMLSP(t, cert)
+	doc := signTestXML(t, testSAMLResponse("jane@example.com"), "_assert1", key)
+
+	sp.now = func() time.Time { return time.Now().Add(time.Hour) }
+	_, err := sp.ParseResponse(encodeTestSAML(doc), testRequestID)
+	assert.Error(t, err)
+}
+
+func TestSAMLAuthnRequestURL(t *testing.T) {
+	_, cert := newTestIdPKey(t)
+	sp := newTestSAMLSP(t, cert)
+
+	target, requestID, err := sp.AuthnRequestURL("relay")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(requestID, "_"))
+
+	u, err := url.Parse(target)
+	require.NoError(t, err)
+	assert.Equal(t, "1", u.Query().Get("tenant"))
+	assert.Equal(t, "relay", u.Query().Get("RelayState"))
+
+	compressed, err := base64.StdEncoding.DecodeString(u.Query().Get("SAMLRequest"))
+	require.NoError(t, err)
+	raw, err := io.ReadAll(flate.NewReader(bytes.NewReader(compressed)))
+	require.NoError(t, err)
+	req, err := parseXMLDocument(bytes.NewReader(raw))
+	require.NoError(t, err)
+	assert.True(t, req.is(samlProtocolNS, "AuthnRequest"))
+	assert.Equal(t, requestID, req.attr("ID"))
+	assert.Equal(t, testACSURL, req.attr("AssertionConsumerServiceURL"))
+	assert.Equal(t, testSPEntityID, req.child(samlAssertionNS, "Issuer").textContent())
+
+	assert.Contains(t, string(sp.Metadata()), `entityID="`+testSPEntityID+`"`)
+	assert.Contains(t, string(sp.Metadata()), `Location="`+testACSURL+`"`)
+}
+
+func TestCanonicalizeExclusive(t *testing.T) {
+	doc := `<root xmlns="urn:d" xmlns:a="urn:a" xmlns:b="urn:b" xmlns:unused="urn:u"><a:child b:z="1" y="&quot;2" x="a&#9;b"><inner>t &amp; &lt; &gt;</inner><!-- c --><b:x/></a:child></root>`
+	root, err := parseXMLDocument(strings.NewReader(doc))
+	require.NoError(t, err)
+
+	assert.Equal(t,
+		`<a:child xmlns:a="urn:a" xmlns:b="urn:b" x="a&#x9;b" y="&quot;2" b:z="1"><inner xmlns="urn:d">t &amp; &lt; &gt;</inner><b:x></b:x></a:child>`,
+		string(canonicalizeExclusive(root.children[0], nil, nil)))
+	assert.Equal(t,
+		`<a:child xmlns:a="urn:a" xmlns:b="urn:b" xmlns:unused="urn:u" x="a&#x9;b" y="&quot;2" b:z="1"><inner xmlns="urn:d">t &amp; &lt; &gt;</inner><b:x></b:x></a:child>`,
+		string(canonicalizeExclusive(root.children[0], nil, []string{"unused"})))
+}
+
+func TestParseCertificatesBareBase64(t *testing.T) {
+	_, pemCert := newTestIdPKey(t)
+	block, _ := pem.Decode([]byte(pemCert))
+	certs, err := ParseCertificates(base64.StdEncoding.EncodeToString(block.Bytes))
+	require.NoError(t, err)
+	assert.Len(t, certs, 1)
+
+	_, err = ParseCertificates("not a certificate")
+	assert.Error(t, err)
+}
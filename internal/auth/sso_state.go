@@ -0,0 +1,73 @@
+package auth
+
+import (
+	"sync"
+	"time"
+)
+
+// SSOLoginTTL bounds how long a user may spend at the identity provider.
+const SSOLoginTTL = 10 * time.Minute
+
+// SSOPendingLogin is what GoatFlow remembers between sending the browser to
+// an identity provider and receiving it back.
+type SSOPendingLogin struct {
+	ProviderID int
+	Verifier   string // OIDC PKCE code verifier
+	Nonce      string // OIDC nonce
+	RequestID  string // SAML AuthnRequest ID
+	// BrowserBound means the state cookie was set and must come back.
+	BrowserBound bool
+	expiresAt    time.Time
+}
+
+// SSOStateStore keeps pending logins server-side, keyed by the state (OIDC)
+// or RelayState (SAML) parameter. Each entry can be taken once.
+type SSOStateStore struct {
+	mu      sync.Mutex
+	pending map[string]SSOPendingLogin
+}
+
+var (
+	defaultSSOStates *SSOStateStore
+	ssoStatesOnce    sync.Once
+)
+
+// GetSSOStateStore returns the process-wide pending login store.
+func GetSSOStateStore() *SSOStateStore {
+	ssoStatesOnce.Do(func() {
+		defaultSSOStates = NewSSOStateStore()
+	})
+	return defaultSSOStates
+}
+
+// NewSSOStateStore creates an empty store.
+func NewSSOStateStore() *SSOStateStore {
+	return &SSOStateStore{pending: make(map[string]SSOPendingLogin)}
+}
+
+// Put remembers a pending login under state.
+func (s *SSOStateStore) Put(state string, login SSOPendingLogin) {
+	now := time.Now()
+	login.expiresAt = now.Add(SSOLoginTTL)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for k, p := range s.pending {
+		if now.After(p.expiresAt) {
+			delete(s.pending, k)
+		}
+	}
+	s.pending[state] = login
+}
+
+// Take returns and removes the pending login for state. Expired logins are
+// reported as missing.
+func (s *SSOStateStore) Take(state string) (SSOPendingLogin, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.pending[state]
+	delete(s.pending, state)
+	if !ok || time.Now().After(p.expiresAt) {
+		return SSOPendingLogin{}, false
+	}
+	return p, true
+}
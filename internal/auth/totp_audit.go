@@ -34,6 +34,14 @@ const (
 	AuditTOTPRecoveryUsed    = "2FA_RECOVERY_CODE_USED"
 )
 
+// WebAuthn audit event types, logged through the same 2FA audit trail.
+const (
+	AuditWebAuthnRegistered    = "2FA_WEBAUTHN_REGISTERED"
+	AuditWebAuthnRemoved       = "2FA_WEBAUTHN_REMOVED"
+	AuditWebAuthnVerifySuccess = "2FA_WEBAUTHN_VERIFY_SUCCESS"
+	AuditWebAuthnVerifyFailed  = "2FA_WEBAUTHN_VERIFY_FAILED"
+)
+
 // LogTOTPAuditEvent logs a 2FA security event.
 // V8 FIX: Provides audit trail for 2FA events.
 func LogTOTPAuditEvent(event TOTPAuditEvent) {
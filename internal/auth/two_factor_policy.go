@@ -0,0 +1,57 @@
+package auth
+
+import "strings"
+
+// Roles a two-factor policy can name.
+const (
+	TwoFactorRoleAgent    = "agent"
+	TwoFactorRoleAdmin    = "admin"
+	TwoFactorRoleCustomer = "customer"
+)
+
+// TwoFactorPolicy decides which roles must complete a second factor (TOTP or
+// WebAuthn) before they can use the application. Requiring agents also
+// covers admins, since every admin is an agent.
+type TwoFactorPolicy struct {
+	Agents    bool
+	Admins    bool
+	Customers bool
+}
+
+// ParseTwoFactorPolicy builds a policy from role names such as
+// ["agent", "customer"]. Unknown names are ignored; "all" enables every role.
+func ParseTwoFactorPolicy(roles []string) TwoFactorPolicy {
+	var p TwoFactorPolicy
+	for _, role := range roles {
+		for _, name := range strings.Split(role, ",") {
+			switch strings.ToLower(strings.TrimSpace(name)) {
+			case TwoFactorRoleAgent, "agents":
+				p.Agents = true
+			case TwoFactorRoleAdmin, "admins":
+				p.Admins = true
+			case TwoFactorRoleCustomer, "customers":
+				p.Customers = true
+			case "all":
+				p.Agents, p.Admins, p.Customers = true, true, true
+			}
+		}
+	}
+	return p
+}
+
+// Enabled reports whether any role is required to use two-factor authentication.
+func (p TwoFactorPolicy) Enabled() bool {
+	return p.Agents || p.Admins || p.Customers
+}
+
+// Requires reports whether a session with the given JWT role must be
+// 2FA-complete. isAdmin should be true for members of the admin group.
+func (p TwoFactorPolicy) Requires(role string, isAdmin bool) bool {
+	switch {
+	case strings.EqualFold(role, "Customer"):
+		return p.Customers
+	case isAdmin || strings.EqualFold(role, "Admin"):
+		return p.Admins || p.Agents
+	}
+	return p.Agents
+}
@@ -0,0 +1,24 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTwoFactorPolicy(t *testing.T) {
+	p := ParseTwoFactorPolicy([]string{"admin", " Customers "})
+	assert.True(t, p.Enabled())
+	assert.True(t, p.Requires("Agent", true))
+	assert.True(t, p.Requires("Admin", false))
+	assert.False(t, p.Requires("Agent", false))
+	assert.True(t, p.Requires("Customer", false))
+
+	p = ParseTwoFactorPolicy([]string{"agent,bogus"})
+	assert.True(t, p.Requires("user", false))
+	assert.True(t, p.Requires("Admin", false), "admins are agents too")
+	assert.False(t, p.Requires("Customer", false))
+
+	assert.False(t, ParseTwoFactorPolicy(nil).Enabled())
+	assert.Equal(t, TwoFactorPolicy{Agents: true, Admins: true, Customers: true}, ParseTwoFactorPolicy([]string{"all"}))
+}
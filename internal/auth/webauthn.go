@@ -0,0 +1,369 @@
+package auth
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WebAuthn verification errors. Handlers map these to 400/401 responses.
+var (
+	ErrWebAuthnChallenge   = errors.New("webauthn: challenge mismatch or expired")
+	ErrWebAuthnOrigin      = errors.New("webauthn: origin not allowed")
+	ErrWebAuthnRPID        = errors.New("webauthn: relying party mismatch")
+	ErrWebAuthnUserPresent = errors.New("webauthn: user presence not asserted")
+	ErrWebAuthnSignature   = errors.New("webauthn: invalid signature")
+	ErrWebAuthnCloned      = errors.New("webauthn: signature counter did not increase")
+	ErrWebAuthnMalformed   = errors.New("webauthn: malformed authenticator response")
+)
+
+// COSE algorithm identifiers accepted for credentials, in order of preference.
+const (
+	COSEAlgES256 = -7
+	COSEAlgEdDSA = -8
+	COSEAlgRS256 = -257
+)
+
+// WebAuthnAlgorithms lists the pubKeyCredParams offered during registration.
+var WebAuthnAlgorithms = []int{COSEAlgES256, COSEAlgEdDSA, COSEAlgRS256}
+
+const (
+	webauthnFlagUserPresent  = 0x01
+	webauthnFlagAttestedData = 0x40
+
+	// WebAuthnChallengeTTL is how long a registration or login challenge stays valid.
+	WebAuthnChallengeTTL = 5 * time.Minute
+)
+
+// WebAuthnRelyingParty identifies this server to authenticators. ID is the
+// effective domain (no scheme or port); Origins are the exact origins the
+// browser may report in clientDataJSON.
+type WebAuthnRelyingParty struct {
+	ID      string
+	Name    string
+	Origins []string
+}
+
+// WebAuthnCredential is a registered security key or platform authenticator.
+type WebAuthnCredential struct {
+	ID         string     `json:"id"`         // base64url credential ID
+	PublicKey  []byte     `json:"public_key"` // COSE_Key as returned by the authenticator
+	Algorithm  int        `json:"alg"`
+	SignCount  uint32     `json:"sign_count"`
+	Name       string     `json:"name"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+type webauthnClientData struct {
+	Type      string `json:"type"`
+	Challenge string `json:"challenge"`
+	Origin    string `json:"origin"`
+}
+
+type webauthnAuthData struct {
+	RPIDHash     []byte
+	Flags        byte
+	SignCount    uint32
+	CredentialID []byte
+	PublicKey    []byte
+}
+
+// NewWebAuthnChallenge returns 32 random bytes, base64url encoded without padding.
+func NewWebAuthnChallenge() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate challenge: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// DecodeWebAuthnBase64 accepts base64url with or without padding, which is
+// what browsers and most client libraries send.
+func DecodeWebAuthnBase64(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
+
+// VerifyRegistration checks the response to navigator.credentials.create()
+// and returns the new credential. Attestation statements are not verified:
+// registration requests "none" attestation, so the authenticator model is
+// not trusted, only the key it generated.
+func (rp WebAuthnRelyingParty) VerifyRegistration(challenge string, clientDataJSON, attestationObject []byte) (*WebAuthnCredential, error) {
+	if err := rp.verifyClientData(clientDataJSON, "webauthn.create", challenge); err != nil {
+		return nil, err
+	}
+
+	decoded, _, err := decodeCBOR(attestationObject)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrWebAuthnMalformed, err)
+	}
+	att, ok := decoded.(map[any]any)
+	if !ok {
+		return nil, ErrWebAuthnMalformed
+	}
+	rawAuthData, ok := att["authData"].([]byte)
+	if !ok {
+		return nil, ErrWebAuthnMalformed
+	}
+
+	authData, err := rp.parseAuthData(rawAuthData)
+	if err != nil {
+		return nil, err
+	}
+	if authData.Flags&webauthnFlagAttestedData == 0 || len(authData.CredentialID) == 0 {
+		return nil, fmt.Errorf("%w: no attested credential data", ErrWebAuthnMalformed)
+	}
+
+	alg, _, err := parseCOSEKey(authData.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &WebAuthnCredential{
+		ID:        base64.RawURLEncoding.EncodeToString(authData.CredentialID),
+		PublicKey: authData.PublicKey,
+		Algorithm: alg,
+		SignCount: authData.SignCount,
+		CreatedAt: time.Now().UTC(),
+	}, nil
+}
+
+// VerifyAssertion checks the response to navigator.credentials.get() against
+// a stored credential and returns the authenticator's new signature counter.
+func (rp WebAuthnRelyingParty) VerifyAssertion(challenge string, cred *WebAuthnCredential, clientDataJSON, authenticatorData, signature []byte) (uint32, error) {
+	if cred == nil {
+		return 0, ErrWebAuthnMalformed
+	}
+	if err := rp.verifyClientData(clientDataJSON, "webauthn.get", challenge); err != nil {
+		return 0, err
+	}
+	authData, err := rp.parseAuthData(authenticatorData)
+	if err != nil {
+		return 0, err
+	}
+
+	_, pub, err := parseCOSEKey(cred.PublicKey)
+	if err != nil {
+		return 0, err
+	}
+	clientHash := sha256.Sum256(clientDataJSON)
+	signed := append(append([]byte(nil), authenticatorData...), clientHash[:]...)
+	if !verifyCOSESignature(pub, signed, signature) {
+		return 0, ErrWebAuthnSignature
+	}
+
+	// Authenticators that do not implement a counter always report zero.
+	if (authData.SignCount != 0 || cred.SignCount != 0) && authData.SignCount <= cred.SignCount {
+		return 0, ErrWebAuthnCloned
+	}
+	return authData.SignCount, nil
+}
+
+func (rp WebAuthnRelyingParty) verifyClientData(raw []byte, wantType, challenge string) error {
+	var cd webauthnClientData
+	if err := json.Unmarshal(raw, &cd); err != nil {
+		return fmt.Errorf("%w: client data: %v", ErrWebAuthnMalformed, err)
+	}
+	if cd.Type != wantType {
+		return fmt.Errorf("%w: unexpected type %q", ErrWebAuthnMalformed, cd.Type)
+	}
+	if challenge == "" || subtle.ConstantTimeCompare([]byte(strings.TrimRight(cd.Challenge, "=")), []byte(challenge)) != 1 {
+		return ErrWebAuthnChallenge
+	}
+	if !slices.Contains(rp.Origins, cd.Origin) {
+		return ErrWebAuthnOrigin
+	}
+	return nil
+}
+
+func (rp WebAuthnRelyingParty) parseAuthData(raw []byte) (*webauthnAuthData, error) {
+	if len(raw) < 37 {
+		return nil, fmt.Errorf("%w: authenticator data too short", ErrWebAuthnMalformed)
+	}
+	ad := &webauthnAuthData{
+		RPIDHash:  raw[:32],
+		Flags:     raw[32],
+		SignCount: binary.BigEndian.Uint32(raw[33:37]),
+	}
+	rpHash := sha256.Sum256([]byte(rp.ID))
+	if subtle.ConstantTimeCompare(ad.RPIDHash, rpHash[:]) != 1 {
+		return nil, ErrWebAuthnRPID
+	}
+	if ad.Flags&webauthnFlagUserPresent == 0 {
+		return nil, ErrWebAuthnUserPresent
+	}
+
+	if ad.Flags&webauthnFlagAttestedData != 0 {
+		rest := raw[37:]
+		if len(rest) < 18 {
+			return nil, fmt.Errorf("%w: attested credential data too short", ErrWebAuthnMalformed)
+		}
+		idLen := int(binary.BigEndian.Uint16(rest[16:18]))
+		rest = rest[18:]
+		if idLen == 0 || idLen > 1023 || len(rest) < idLen {
+			return nil, fmt.Errorf("%w: bad credential ID length", ErrWebAuthnMalformed)
+		}
+		ad.CredentialID = rest[:idLen]
+		rest = rest[idLen:]
+		_, remaining, err := decodeCBOR(rest)
+		if err != nil {
+			return nil, fmt.Errorf("%w: credential public key: %v", ErrWebAuthnMalformed, err)
+		}
+		ad.PublicKey = rest[:len(rest)-len(remaining)]
+	}
+	return ad, nil
+}
+
+// parseCOSEKey decodes a COSE_Key into a Go public key.
+func parseCOSEKey(raw []byte) (int, crypto.PublicKey, error) {
+	decoded, _, err := decodeCBOR(raw)
+	if err != nil {
+		return 0, nil, fmt.Errorf("%w: public key: %v", ErrWebAuthnMalformed, err)
+	}
+	key, ok := decoded.(map[any]any)
+	if !ok {
+		return 0, nil, fmt.Errorf("%w: public key is not a map", ErrWebAuthnMalformed)
+	}
+	kty, _ := key[int64(1)].(int64)
+	alg, _ := key[int64(3)].(int64)
+
+	switch {
+	case kty == 2 && alg == COSEAlgES256:
+		crv, _ := key[int64(-1)].(int64)
+		x, _ := key[int64(-2)].([]byte)
+		y, _ := key[int64(-3)].([]byte)
+		if crv != 1 || len(x) != 32 || len(y) != 32 {
+			return 0, nil, fmt.Errorf("%w: unsupported EC2 key", ErrWebAuthnMalformed)
+		}
+		pub := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !pub.Curve.IsOnCurve(pub.X, pub.Y) {
+			return 0, nil, fmt.Errorf("%w: EC2 point not on curve", ErrWebAuthnMalformed)
+		}
+		return COSEAlgES256, pub, nil
+	case kty == 1 && alg == COSEAlgEdDSA:
+		crv, _ := key[int64(-1)].(int64)
+		x, _ := key[int64(-2)].([]byte)
+		if crv != 6 || len(x) != ed25519.PublicKeySize {
+			return 0, nil, fmt.Errorf("%w: unsupported OKP key", ErrWebAuthnMalformed)
+		}
+		return COSEAlgEdDSA, ed25519.PublicKey(x), nil
+	case kty == 3 && alg == COSEAlgRS256:
+		n, _ := key[int64(-1)].([]byte)
+		e, _ := key[int64(-2)].([]byte)
+		if len(n) < 256 || len(e) == 0 || len(e) > 4 {
+			return 0, nil, fmt.Errorf("%w: unsupported RSA key", ErrWebAuthnMalformed)
+		}
+		exp := 0
+		for _, b := range e {
+			exp = exp<<8 | int(b)
+		}
+		return COSEAlgRS256, &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: exp}, nil
+	}
+	return 0, nil, fmt.Errorf("%w: unsupported key type %d / algorithm %d", ErrWebAuthnMalformed, kty, alg)
+}
+
+func verifyCOSESignature(pub crypto.PublicKey, data, sig []byte) bool {
+	switch k := pub.(type) {
+	case *ecdsa.PublicKey:
+		digest := sha256.Sum256(data)
+		return ecdsa.VerifyASN1(k, digest[:], sig)
+	case ed25519.PublicKey:
+		return ed25519.Verify(k, data, sig)
+	case *rsa.PublicKey:
+		digest := sha256.Sum256(data)
+		return rsa.VerifyPKCS1v15(k, crypto.SHA256, digest[:], sig) == nil
+	}
+	return false
+}
+
+// FindWebAuthnCredential returns the credential with the given base64url ID.
+func FindWebAuthnCredential(creds []WebAuthnCredential, id string) *WebAuthnCredential {
+	raw, err := DecodeWebAuthnBase64(id)
+	if err != nil {
+		return nil
+	}
+	for i := range creds {
+		stored, err := DecodeWebAuthnBase64(creds[i].ID)
+		if err == nil && bytes.Equal(stored, raw) {
+			return &creds[i]
+		}
+	}
+	return nil
+}
+
+// WebAuthnChallengeStore keeps outstanding challenges server-side so a
+// response can only be used once and only by the session that requested it.
+type WebAuthnChallengeStore struct {
+	mu         sync.Mutex
+	challenges map[string]webauthnChallenge
+}
+
+type webauthnChallenge struct {
+	value     string
+	expiresAt time.Time
+}
+
+var (
+	defaultWebAuthnChallenges *WebAuthnChallengeStore
+	webauthnChallengesOnce    sync.Once
+)
+
+// GetWebAuthnChallengeStore returns the process-wide challenge store.
+func GetWebAuthnChallengeStore() *WebAuthnChallengeStore {
+	webauthnChallengesOnce.Do(func() {
+		defaultWebAuthnChallenges = NewWebAuthnChallengeStore()
+	})
+	return defaultWebAuthnChallenges
+}
+
+// NewWebAuthnChallengeStore creates an empty challenge store.
+func NewWebAuthnChallengeStore() *WebAuthnChallengeStore {
+	return &WebAuthnChallengeStore{challenges: make(map[string]webauthnChallenge)}
+}
+
+// Issue creates a new challenge for key, replacing any outstanding one.
+func (s *WebAuthnChallengeStore) Issue(key string) (string, error) {
+	challenge, err := NewWebAuthnChallenge()
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for k, ch := range s.challenges {
+		if now.After(ch.expiresAt) {
+			delete(s.challenges, k)
+		}
+	}
+	s.challenges[key] = webauthnChallenge{value: challenge, expiresAt: now.Add(WebAuthnChallengeTTL)}
+	return challenge, nil
+}
+
+// Take returns and removes the challenge for key. Expired challenges are
+// reported as missing.
+func (s *WebAuthnChallengeStore) Take(key string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ch, ok := s.challenges[key]
+	delete(s.challenges, key)
+	if !ok || time.Now().After(ch.expiresAt) {
+		return "", false
+	}
+	return ch.value, true
+}
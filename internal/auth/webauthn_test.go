@@ -0,0 +1,250 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// encodeTestCBOR is a minimal encoder for building authenticator responses.
+func encodeTestCBOR(v any) []byte {
+	head := func(major byte, n uint64) []byte {
+		switch {
+		case n < 24:
+			return []byte{major<<5 | byte(n)}
+		case n < 1<<8:
+			return []byte{major<<5 | 24, byte(n)}
+		case n < 1<<16:
+			b := []byte{major<<5 | 25, 0, 0}
+			binary.BigEndian.PutUint16(b[1:], uint16(n))
+			return b
+		}
+		b := []byte{major<<5 | 26, 0, 0, 0, 0}
+		binary.BigEndian.PutUint32(b[1:], uint32(n))
+		return b
+	}
+	switch x := v.(type) {
+	case int:
+		if x < 0 {
+			return head(1, uint64(-1-x))
+		}
+		return head(0, uint64(x))
+	case []byte:
+		return append(head(2, uint64(len(x))), x...)
+	case string:
+		return append(head(3, uint64(len(x))), x...)
+	case map[any]any:
+		keys := make([]any, 0, len(x))
+		for k := range x {
+			keys = append(keys, k)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			return string(encodeTestCBOR(keys[i])) < string(encodeTestCBOR(keys[j]))
+		})
+		out := head(5, uint64(len(x)))
+		for _, k := range keys {
+			out = append(out, encodeTestCBOR(k)...)
+			out = append(out, encodeTestCBOR(x[k])...)
+		}
+		return out
+	}
+	panic("unsupported test CBOR value")
+}
+
+type testAuthenticator struct {
+	key    *ecdsa.PrivateKey
+	credID []byte
+	count  uint32
+}
+
+func newTestAuthenticator(t *testing.T) *testAuthenticator {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	return &testAuthenticator{key: key, credID: []byte("credential-0001")}
+}
+
+func (a *testAuthenticator) coseKey() []byte {
+	x := make([]byte, 32)
+	y := make([]byte, 32)
+	a.key.X.FillBytes(x)
+	a.key.Y.FillBytes(y)
+	return encodeTestCBOR(map[any]any{1: 2, 3: -7, -1: 1, -2: x, -3: y})
+}
+
+func (a *testAuthenticator) authData(rpID string, flags byte, attested bool) []byte {
+	rpHash := sha256.Sum256([]byte(rpID))
+	out := append([]byte(nil), rpHash[:]...)
+	out = append(out, flags)
+	out = binary.BigEndian.AppendUint32(out, a.count)
+	if attested {
+		out = append(out, make([]byte, 16)...) // AAGUID
+		out = binary.BigEndian.AppendUint16(out, uint16(len(a.credID)))
+		out = append(out, a.credID...)
+		out = append(out, a.coseKey()...)
+	}
+	return out
+}
+
+func testClientData(typ, challenge, origin string) []byte {
+	b, _ := json.Marshal(map[string]string{"type": typ, "challenge": challenge, "origin": origin})
+	return b
+}
+
+func (a *testAuthenticator) register(rpID, challenge, origin string) (clientData, attestation []byte) {
+	clientData = testClientData("webauthn.create", challenge, origin)
+	attestation = encodeTestCBOR(map[any]any{
+		"fmt":      "none",
+		"attStmt":  map[any]any{},
+		"authData": a.authData(rpID, 0x41, true),
+	})
+	return clientData, attestation
+}
+
+func (a *testAuthenticator) assert(t *testing.T, rpID, challenge, origin string) (clientData, authData, sig []byte) {
+	a.count++
+	clientData = testClientData("webauthn.get", challenge, origin)
+	authData = a.authData(rpID, 0x05, false)
+	clientHash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(append([]byte(nil), authData...), clientHash[:]...))
+	sig, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	require.NoError(t, err)
+	return clientData, authData, sig
+}
+
+func testRelyingParty() WebAuthnRelyingParty {
+	return WebAuthnRelyingParty{ID: "helpdesk.example.com", Name: "GoatFlow", Origins: []string{"https://helpdesk.example.com"}}
+}
+
+func TestWebAuthn_RegisterAndAssert(t *testing.T) {
+	rp := testRelyingParty()
+	authn := newTestAuthenticator(t)
+
+	clientData, attestation := authn.register(rp.ID, "reg-challenge", rp.Origins[0])
+	cred, err := rp.VerifyRegistration("reg-challenge", clientData, attestation)
+	require.NoError(t, err)
+	assert.Equal(t, base64.RawURLEncoding.EncodeToString(authn.credID), cred.ID)
+	assert.Equal(t, COSEAlgES256, cred.Algorithm)
+	assert.Equal(t, uint32(0), cred.SignCount)
+
+	clientData, authData, sig := authn.assert(t, rp.ID, "login-challenge", rp.Origins[0])
+	count, err := rp.VerifyAssertion("login-challenge", cred, clientData, authData, sig)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(1), count)
+
+	// A replayed assertion reuses the counter and must be rejected.
+	cred.SignCount = count
+	_, err = rp.VerifyAssertion("login-challenge", cred, clientData, authData, sig)
+	assert.ErrorIs(t, err, ErrWebAuthnCloned)
+}
+
+func TestWebAuthn_RejectsBadResponses(t *testing.T) {
+	rp := testRelyingParty()
+	authn := newTestAuthenticator(t)
+	clientData, attestation := authn.register(rp.ID, "reg-challenge", rp.Origins[0])
+	cred, err := rp.VerifyRegistration("reg-challenge", clientData, attestation)
+	require.NoError(t, err)
+
+	t.Run("wrong challenge", func(t *testing.T) {
+		_, err := rp.VerifyRegistration("other", clientData, attestation)
+		assert.ErrorIs(t, err, ErrWebAuthnChallenge)
+	})
+
+	t.Run("wrong origin", func(t *testing.T) {
+		cd, att := authn.register(rp.ID, "c", "https://evil.example.net")
+		_, err := rp.VerifyRegistration("c", cd, att)
+		assert.ErrorIs(t, err, ErrWebAuthnOrigin)
+	})
+
+	t.Run("wrong rp id", func(t *testing.T) {
+		cd, att := authn.register("evil.example.net", "c", rp.Origins[0])
+		_, err := rp.VerifyRegistration("c", cd, att)
+		assert.ErrorIs(t, err, ErrWebAuthnRPID)
+	})
+
+	t.Run("create response used for login", func(t *testing.T) {
+		cd, ad, sig := authn.assert(t, rp.ID, "c", rp.Origins[0])
+		cd = testClientData("webauthn.create", "c", rp.Origins[0])
+		_, err := rp.VerifyAssertion("c", cred, cd, ad, sig)
+		assert.ErrorIs(t, err, ErrWebAuthnMalformed)
+	})
+
+	t.Run("tampered signature", func(t *testing.T) {
+		cd, ad, sig := authn.assert(t, rp.ID, "c", rp.Origins[0])
+		ad[len(ad)-1]++
+		_, err := rp.VerifyAssertion("c", cred, cd, ad, sig)
+		assert.ErrorIs(t, err, ErrWebAuthnSignature)
+	})
+
+	t.Run("other authenticator", func(t *testing.T) {
+		other := newTestAuthenticator(t)
+		cd, ad, sig := other.assert(t, rp.ID, "c", rp.Origins[0])
+		_, err := rp.VerifyAssertion("c", cred, cd, ad, sig)
+		assert.ErrorIs(t, err, ErrWebAuthnSignature)
+	})
+
+	t.Run("truncated attestation", func(t *testing.T) {
+		_, err := rp.VerifyRegistration("reg-challenge", clientData, attestation[:len(attestation)/2])
+		assert.ErrorIs(t, err, ErrWebAuthnMalformed)
+	})
+}
+
+func TestWebAuthn_EdDSAKey(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	cose := encodeTestCBOR(map[any]any{1: 1, 3: -8, -1: 6, -2: []byte(pub)})
+
+	alg, parsed, err := parseCOSEKey(cose)
+	require.NoError(t, err)
+	assert.Equal(t, COSEAlgEdDSA, alg)
+	assert.True(t, verifyCOSESignature(parsed, []byte("data"), ed25519.Sign(priv, []byte("data"))))
+}
+
+func TestDecodeCBOR(t *testing.T) {
+	v, rest, err := decodeCBOR(append(encodeTestCBOR(map[any]any{"a": -300, 1: []byte{1, 2}}), 0xff))
+	require.NoError(t, err)
+	assert.Equal(t, []byte{0xff}, rest)
+	assert.Equal(t, map[any]any{"a": int64(-300), int64(1): []byte{1, 2}}, v)
+
+	_, _, err = decodeCBOR([]byte{0x5f}) // indefinite-length byte string
+	assert.Error(t, err)
+	_, _, err = decodeCBOR([]byte{0x9b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff})
+	assert.Error(t, err, "huge array length must not allocate")
+}
+
+func TestFindWebAuthnCredential(t *testing.T) {
+	creds := []WebAuthnCredential{{ID: "AQID"}, {ID: "BAUG"}}
+	assert.Equal(t, &creds[1], FindWebAuthnCredential(creds, "BAUG"))
+	assert.Equal(t, &creds[0], FindWebAuthnCredential(creds, "AQID="))
+	assert.Nil(t, FindWebAuthnCredential(creds, "CQoL"))
+	assert.Nil(t, FindWebAuthnCredential(creds, "!!"))
+}
+
+func TestWebAuthnChallengeStore(t *testing.T) {
+	store := NewWebAuthnChallengeStore()
+	challenge, err := store.Issue("agent:1")
+	require.NoError(t, err)
+
+	got, ok := store.Take("agent:1")
+	assert.True(t, ok)
+	assert.Equal(t, challenge, got)
+
+	_, ok = store.Take("agent:1")
+	assert.False(t, ok, "challenges are single use")
+
+	_, err = store.Issue("agent:2")
+	require.NoError(t, err)
+	store.challenges["agent:2"] = webauthnChallenge{value: "x", expiresAt: time.Now().Add(-time.Second)}
+	_, ok = store.Take("agent:2")
+	assert.False(t, ok, "expired challenges are rejected")
+}
@@ -0,0 +1,490 @@
+package auth
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+	"strings"
+
+	// Hash implementations referenced through crypto.Hash below.
+	_ "crypto/sha1" //nolint:gosec // SHA-1 signatures are still issued by older IdPs
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+)
+
+// XML Signature support for SAML. Only what SAML identity providers use is
+// implemented: enveloped signatures over an element referenced by ID,
+// exclusive canonicalization, and RSA or ECDSA keys taken from the configured
+// certificate (never from the document's KeyInfo).
+
+const (
+	xmlDSigNS        = "http://www.w3.org/2000/09/xmldsig#"
+	xmlExcC14N       = "http://www.w3.org/2001/10/xml-exc-c14n#"
+	xmlEnvelopedSig  = "http://www.w3.org/2000/09/xmldsig#enveloped-signature"
+	xmlNamespaceURI  = "http://www.w3.org/XML/1998/namespace"
+	xmlDSigSHA1      = "http://www.w3.org/2000/09/xmldsig#sha1"
+	xmlEncSHA256     = "http://www.w3.org/2001/04/xmlenc#sha256"
+	xmlEncSHA512     = "http://www.w3.org/2001/04/xmlenc#sha512"
+	xmlDSigRSASHA1   = "http://www.w3.org/2000/09/xmldsig#rsa-sha1"
+	xmlDSigRSASHA256 = "http://www.w3.org/2001/04/xmldsig-more#rsa-sha256"
+	xmlDSigRSASHA512 = "http://www.w3.org/2001/04/xmldsig-more#rsa-sha512"
+	xmlDSigECSHA256  = "http://www.w3.org/2001/04/xmldsig-more#ecdsa-sha256"
+	xmlDSigECSHA512  = "http://www.w3.org/2001/04/xmldsig-more#ecdsa-sha512"
+)
+
+// ErrXMLUnsigned is returned when an element carries no signature.
+var ErrXMLUnsigned = errors.New("xml element is not signed")
+
+var xmlDigestMethods = map[string]crypto.Hash{
+	xmlDSigSHA1:  crypto.SHA1,
+	xmlEncSHA256: crypto.SHA256,
+	xmlEncSHA512: crypto.SHA512,
+}
+
+var xmlSignatureMethods = map[string]crypto.Hash{
+	xmlDSigRSASHA1:   crypto.SHA1,
+	xmlDSigRSASHA256: crypto.SHA256,
+	xmlDSigRSASHA512: crypto.SHA512,
+	xmlDSigECSHA256:  crypto.SHA256,
+	xmlDSigECSHA512:  crypto.SHA512,
+}
+
+type xmlNodeKind int
+
+const (
+	xmlElementNode xmlNodeKind = iota
+	xmlTextNode
+	xmlProcInstNode
+)
+
+// xmlNode is a minimal DOM that keeps namespace prefixes as written, which
+// canonicalization needs and encoding/xml's Unmarshal discards.
+type xmlNode struct {
+	kind     xmlNodeKind
+	parent   *xmlNode
+	name     xml.Name // Space holds the prefix, not the namespace URI
+	attrs    []xml.Attr
+	children []*xmlNode
+	text     string // Character data, or the processing instruction body
+}
+
+// parseXMLDocument builds the DOM for a document and returns its root
+// element. DTDs are rejected outright.
+func parseXMLDocument(r io.Reader) (*xmlNode, error) {
+	dec := xml.NewDecoder(r)
+	var root, cur *xmlNode
+	for {
+		tok, err := dec.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parse xml: %w", err)
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if root != nil && cur == nil {
+				return nil, errors.New("parse xml: multiple root elements")
+			}
+			n := &xmlNode{kind: xmlElementNode, parent: cur, name: t.Name, attrs: append([]xml.Attr(nil), t.Attr...)}
+			if cur == nil {
+				root = n
+			} else {
+				cur.children = append(cur.children, n)
+			}
+			cur = n
+		case xml.EndElement:
+			if cur == nil || cur.name != t.Name {
+				return nil, errors.New("parse xml: mismatched end element")
+			}
+			cur = cur.parent
+		case xml.CharData:
+			if cur != nil {
+				cur.children = append(cur.children, &xmlNode{kind: xmlTextNode, parent: cur, text: string(t)})
+			}
+		case xml.ProcInst:
+			if cur != nil {
+				cur.children = append(cur.children, &xmlNode{kind: xmlProcInstNode, parent: cur, name: xml.Name{Local: t.Target}, text: string(t.Inst)})
+			}
+		case xml.Directive:
+			return nil, errors.New("parse xml: DTDs are not allowed")
+		}
+	}
+	if root == nil || cur != nil {
+		return nil, errors.New("parse xml: incomplete document")
+	}
+	return root, nil
+}
+
+// lookupNS resolves a prefix ("" for the default namespace) in scope at n.
+func (n *xmlNode) lookupNS(prefix string) (string, bool) {
+	if prefix == "xml" {
+		return xmlNamespaceURI, true
+	}
+	for e := n; e != nil; e = e.parent {
+		for _, a := range e.attrs {
+			if (prefix == "" && a.Name.Space == "" && a.Name.Local == "xmlns") ||
+				(prefix != "" && a.Name.Space == "xmlns" && a.Name.Local == prefix) {
+				return a.Value, true
+			}
+		}
+	}
+	return "", false
+}
+
+// namespace returns the namespace URI of an element.
+func (n *xmlNode) namespace() string {
+	uri, _ := n.lookupNS(n.name.Space)
+	return uri
+}
+
+func (n *xmlNode) is(ns, local string) bool {
+	return n != nil && n.kind == xmlElementNode && n.name.Local == local && n.namespace() == ns
+}
+
+// child returns the first child element with the given name. Like the other
+// accessors it accepts a nil node so lookups can be chained.
+func (n *xmlNode) child(ns, local string) *xmlNode {
+	if n == nil {
+		return nil
+	}
+	for _, c := range n.children {
+		if c.is(ns, local) {
+			return c
+		}
+	}
+	return nil
+}
+
+// childrenNamed returns every child element with the given name.
+func (n *xmlNode) childrenNamed(ns, local string) []*xmlNode {
+	if n == nil {
+		return nil
+	}
+	var out []*xmlNode
+	for _, c := range n.children {
+		if c.is(ns, local) {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// attr returns an unprefixed attribute value.
+func (n *xmlNode) attr(local string) string {
+	if n == nil {
+		return ""
+	}
+	for _, a := range n.attrs {
+		if a.Name.Space == "" && a.Name.Local == local {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// textContent returns the concatenated character data of an element.
+func (n *xmlNode) textContent() string {
+	if n == nil {
+		return ""
+	}
+	var b strings.Builder
+	for _, c := range n.children {
+		switch c.kind {
+		case xmlTextNode:
+			b.WriteString(c.text)
+		case xmlElementNode:
+			b.WriteString(c.textContent())
+		}
+	}
+	return b.String()
+}
+
+// canonicalizeExclusive serializes the subtree at n with Exclusive XML
+// Canonicalization 1.0 (without comments), leaving out the exclude subtree.
+// inclusive lists prefixes from an InclusiveNamespaces PrefixList.
+func canonicalizeExclusive(n, exclude *xmlNode, inclusive []string) []byte {
+	var buf bytes.Buffer
+	writeExcC14N(&buf, n, exclude, inclusive, map[string]string{"": ""})
+	return buf.Bytes()
+}
+
+func writeExcC14N(buf *bytes.Buffer, n, exclude *xmlNode, inclusive []string, rendered map[string]string) {
+	// Namespaces visibly used by the element or its attributes, plus the
+	// inclusive prefixes, are declared unless an output ancestor already
+	// declared them with the same value.
+	prefixes := []string{n.name.Space}
+	var attrs []xml.Attr
+	for _, a := range n.attrs {
+		if a.Name.Space == "xmlns" || (a.Name.Space == "" && a.Name.Local == "xmlns") {
+			continue
+		}
+		attrs = append(attrs, a)
+		if a.Name.Space != "" && a.Name.Space != "xml" {
+			prefixes = append(prefixes, a.Name.Space)
+		}
+	}
+	for _, p := range inclusive {
+		if p == "#default" {
+			p = ""
+		}
+		if _, ok := n.lookupNS(p); ok {
+			prefixes = append(prefixes, p)
+		}
+	}
+
+	scope := make(map[string]string, len(rendered))
+	for k, v := range rendered {
+		scope[k] = v
+	}
+	decls := make(map[string]string)
+	for _, p := range prefixes {
+		if p == "xml" {
+			continue
+		}
+		uri, ok := n.lookupNS(p)
+		if !ok && p != "" {
+			continue
+		}
+		if prev, seen := scope[p]; seen && prev == uri {
+			continue
+		}
+		scope[p] = uri
+		decls[p] = uri
+	}
+	declPrefixes := make([]string, 0, len(decls))
+	for p := range decls {
+		declPrefixes = append(declPrefixes, p)
+	}
+	sort.Strings(declPrefixes)
+
+	sort.SliceStable(attrs, func(i, j int) bool {
+		ui, uj := attrNamespace(n, attrs[i]), attrNamespace(n, attrs[j])
+		if ui != uj {
+			return ui < uj
+		}
+		return attrs[i].Name.Local < attrs[j].Name.Local
+	})
+
+	buf.WriteByte('<')
+	buf.WriteString(qualifiedName(n.name))
+	for _, p := range declPrefixes {
+		if p == "" {
+			buf.WriteString(` xmlns="`)
+		} else {
+			buf.WriteString(` xmlns:` + p + `="`)
+		}
+		buf.WriteString(escapeC14NAttr(decls[p]))
+		buf.WriteByte('"')
+	}
+	for _, a := range attrs {
+		buf.WriteByte(' ')
+		buf.WriteString(qualifiedName(a.Name))
+		buf.WriteString(`="`)
+		buf.WriteString(escapeC14NAttr(a.Value))
+		buf.WriteByte('"')
+	}
+	buf.WriteByte('>')
+
+	for _, c := range n.children {
+		switch c.kind {
+		case xmlElementNode:
+			if c != exclude {
+				writeExcC14N(buf, c, exclude, inclusive, scope)
+			}
+		case xmlTextNode:
+			buf.WriteString(escapeC14NText(c.text))
+		case xmlProcInstNode:
+			buf.WriteString("<?" + c.name.Local)
+			if c.text != "" {
+				buf.WriteString(" " + c.text)
+			}
+			buf.WriteString("?>")
+		}
+	}
+
+	buf.WriteString("</")
+	buf.WriteString(qualifiedName(n.name))
+	buf.WriteByte('>')
+}
+
+func attrNamespace(n *xmlNode, a xml.Attr) string {
+	if a.Name.Space == "" {
+		return ""
+	}
+	uri, _ := n.lookupNS(a.Name.Space)
+	return uri
+}
+
+func qualifiedName(name xml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+	return name.Space + ":" + name.Local
+}
+
+var (
+	c14nTextEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "\r", "&#xD;")
+	c14nAttrEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", `"`, "&quot;", "\t", "&#x9;", "\n", "&#xA;", "\r", "&#xD;")
+)
+
+func escapeC14NText(s string) string { return c14nTextEscaper.Replace(s) }
+func escapeC14NAttr(s string) string { return c14nAttrEscaper.Replace(s) }
+
+// verifyEnvelopedSignature checks the ds:Signature that is a direct child of
+// el and signs el itself. Callers must read data only from el afterwards;
+// that is what keeps signature wrapping attacks out.
+func verifyEnvelopedSignature(el *xmlNode, certs []*x509.Certificate) error {
+	sig := el.child(xmlDSigNS, "Signature")
+	if sig == nil {
+		return ErrXMLUnsigned
+	}
+	signedInfo := sig.child(xmlDSigNS, "SignedInfo")
+	if signedInfo == nil {
+		return errors.New("signature has no SignedInfo")
+	}
+
+	c14n := signedInfo.child(xmlDSigNS, "CanonicalizationMethod")
+	if c14n.attr("Algorithm") != xmlExcC14N {
+		return fmt.Errorf("unsupported canonicalization %q", c14n.attr("Algorithm"))
+	}
+	sigHash, ok := xmlSignatureMethods[signedInfo.child(xmlDSigNS, "SignatureMethod").attr("Algorithm")]
+	if !ok {
+		return errors.New("unsupported signature method")
+	}
+
+	refs := signedInfo.childrenNamed(xmlDSigNS, "Reference")
+	if len(refs) != 1 {
+		return errors.New("signature must contain exactly one reference")
+	}
+	ref := refs[0]
+	id := el.attr("ID")
+	if id == "" || ref.attr("URI") != "#"+id {
+		return errors.New("signature does not reference the signed element")
+	}
+
+	var enveloped, exclusive bool
+	var refInclusive []string
+	if transforms := ref.child(xmlDSigNS, "Transforms"); transforms != nil {
+		for _, t := range transforms.childrenNamed(xmlDSigNS, "Transform") {
+			switch t.attr("Algorithm") {
+			case xmlEnvelopedSig:
+				enveloped = true
+			case xmlExcC14N:
+				exclusive = true
+				refInclusive = inclusivePrefixes(t)
+			default:
+				return fmt.Errorf("unsupported transform %q", t.attr("Algorithm"))
+			}
+		}
+	}
+	if !enveloped || !exclusive {
+		return errors.New("reference must use the enveloped signature and exclusive canonicalization transforms")
+	}
+
+	digestHash, ok := xmlDigestMethods[ref.child(xmlDSigNS, "DigestMethod").attr("Algorithm")]
+	if !ok {
+		return errors.New("unsupported digest method")
+	}
+	wantDigest, err := base64.StdEncoding.DecodeString(stripXMLSpace(ref.child(xmlDSigNS, "DigestValue").textContent()))
+	if err != nil {
+		return errors.New("invalid digest value")
+	}
+	h := digestHash.New()
+	h.Write(canonicalizeExclusive(el, sig, refInclusive))
+	if subtle.ConstantTimeCompare(h.Sum(nil), wantDigest) != 1 {
+		return errors.New("digest mismatch")
+	}
+
+	sigValue, err := base64.StdEncoding.DecodeString(stripXMLSpace(sig.child(xmlDSigNS, "SignatureValue").textContent()))
+	if err != nil || len(sigValue) == 0 {
+		return errors.New("invalid signature value")
+	}
+	h = sigHash.New()
+	h.Write(canonicalizeExclusive(signedInfo, nil, inclusivePrefixes(c14n)))
+	hashed := h.Sum(nil)
+
+	for _, cert := range certs {
+		switch pub := cert.PublicKey.(type) {
+		case *rsa.PublicKey:
+			if rsa.VerifyPKCS1v15(pub, sigHash, hashed, sigValue) == nil {
+				return nil
+			}
+		case *ecdsa.PublicKey:
+			// XML Signature encodes ECDSA as r || s, not ASN.1.
+			half := len(sigValue) / 2
+			r, s := new(big.Int).SetBytes(sigValue[:half]), new(big.Int).SetBytes(sigValue[half:])
+			if ecdsa.Verify(pub, hashed, r, s) {
+				return nil
+			}
+		}
+	}
+	return errors.New("signature verification failed")
+}
+
+func inclusivePrefixes(n *xmlNode) []string {
+	if n == nil {
+		return nil
+	}
+	if incl := n.child(xmlExcC14N, "InclusiveNamespaces"); incl != nil {
+		return strings.Fields(incl.attr("PrefixList"))
+	}
+	return nil
+}
+
+func stripXMLSpace(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == ' ' || r == '\t' || r == '\n' || r == '\r' {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// ParseCertificates reads one or more certificates from PEM, or from the
+// bare base64 DER that IdP metadata and admin forms often contain.
+func ParseCertificates(data string) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	rest := []byte(strings.TrimSpace(data))
+	if !bytes.Contains(rest, []byte("-----BEGIN")) {
+		der, err := base64.StdEncoding.DecodeString(stripXMLSpace(string(rest)))
+		if err != nil {
+			return nil, errors.New("certificate is neither PEM nor base64 DER")
+		}
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return nil, fmt.Errorf("parse certificate: %w", err)
+		}
+		return []*x509.Certificate{cert}, nil
+	}
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("parse certificate: %w", err)
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("no certificate found")
+	}
+	return certs, nil
+}
@@ -0,0 +1,270 @@
+// Package backup writes and restores backups of an installation: the
+// database, the sysconfig settings, the attachments kept on the filesystem
+// and the plugin binaries, together in one versioned archive.
+//
+// An archive is a tar file. Every member is gzip-compressed on its own and
+// listed in manifest.json, the last member, with the SHA-256 checksum of
+// its uncompressed content; restore verifies all of them before it changes
+// anything. The database is read in a single read-only transaction, so the
+// tables in an archive are a consistent snapshot.
+//
+// An incremental archive stores only what changed since its base archive:
+// tables whose dump differs and files whose size or modification time
+// differs. Everything else is taken from the archive that holds it, which
+// must be kept in the same directory; Prune never removes an archive that
+// a kept archive still needs.
+package backup
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// FormatVersion is the version of the archive layout written by Create.
+const FormatVersion = 1
+
+// Kinds of archive.
+const (
+	KindFull        = "full"
+	KindIncremental = "incremental"
+)
+
+// Sections of an archive.
+const (
+	SectionDatabase    = "database"
+	SectionSysconfig   = "sysconfig"
+	SectionAttachments = "attachments"
+	SectionPlugins     = "plugins"
+)
+
+// manifestName is the archive member that lists the others.
+const manifestName = "manifest.json"
+
+// Errors returned by the package.
+var (
+	ErrFormat        = errors.New("not a supported backup archive")
+	ErrChecksum      = errors.New("backup checksum mismatch")
+	ErrMissingBase   = errors.New("base archive of incremental backup not found")
+	ErrSchemaVersion = errors.New("database schema version does not match the backup")
+	ErrDriver        = errors.New("backup was taken from a different database type")
+	ErrDirtySchema   = errors.New("database schema is dirty")
+)
+
+// Manifest describes an archive.
+type Manifest struct {
+	Format        int       `json:"format"`
+	Kind          string    `json:"kind"`
+	Base          string    `json:"base,omitempty"` // File name of the base archive of an incremental backup
+	CreatedAt     time.Time `json:"created_at"`
+	AppVersion    string    `json:"app_version"`
+	Driver        string    `json:"driver"`
+	SchemaVersion uint      `json:"schema_version"`
+	Entries       []Entry   `json:"entries"`
+}
+
+// Entry is one table dump or file of a backup.
+type Entry struct {
+	Path    string    `json:"path"`
+	Section string    `json:"section"`
+	Size    int64     `json:"size"` // Uncompressed
+	SHA256  string    `json:"sha256"`
+	Mode    int64     `json:"mode,omitempty"`    // Files only
+	ModTime time.Time `json:"mod_time,omitzero"` // Files only
+	Archive string    `json:"archive,omitempty"` // File name of the archive holding the content, when not this one
+}
+
+// Sources are the directories backed up next to the database. An empty
+// directory is skipped.
+type Sources struct {
+	Attachments string
+	Plugins     string
+}
+
+// SourcesFromEnv returns the directories the application uses: the
+// filesystem article storage (ARTICLE_STORAGE_FS_PATH) and the plugin
+// directory (PLUGIN_DIR, or plugins in CONFIG_DIR).
+func SourcesFromEnv() Sources {
+	attachments := os.Getenv("ARTICLE_STORAGE_FS_PATH")
+	if attachments == "" {
+		attachments = "/opt/goatflow/var/article"
+	}
+	plugins := os.Getenv("PLUGIN_DIR")
+	if plugins == "" {
+		configDir := os.Getenv("CONFIG_DIR")
+		if configDir == "" {
+			configDir = "/app/config"
+		}
+		plugins = filepath.Join(configDir, "plugins")
+	}
+	return Sources{Attachments: attachments, Plugins: plugins}
+}
+
+// dir returns the directory of a file section.
+func (s Sources) dir(section string) string {
+	switch section {
+	case SectionAttachments:
+		return s.Attachments
+	case SectionPlugins:
+		return s.Plugins
+	}
+	return ""
+}
+
+// archiveName returns the file name of an archive created at t.
+func archiveName(t time.Time, kind string) string {
+	suffix := "full"
+	if kind == KindIncremental {
+		suffix = "incr"
+	}
+	return fmt.Sprintf("goatflow-%s-%s.tar", t.UTC().Format("20060102T150405Z"), suffix)
+}
+
+// ReadManifest reads the manifest of the archive at path.
+func ReadManifest(path string) (*Manifest, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	// Members are skipped by seeking, so finding the manifest at the end
+	// does not read the content.
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil, fmt.Errorf("%w: %s has no manifest", ErrFormat, filepath.Base(path))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", ErrFormat, filepath.Base(path), err)
+		}
+		if hdr.Name != manifestName {
+			continue
+		}
+		var m Manifest
+		if err := json.NewDecoder(tr).Decode(&m); err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", ErrFormat, filepath.Base(path), err)
+		}
+		if m.Format < 1 || m.Format > FormatVersion {
+			return nil, fmt.Errorf("%w: %s has format %d, this version reads up to %d",
+				ErrFormat, filepath.Base(path), m.Format, FormatVersion)
+		}
+		return &m, nil
+	}
+}
+
+// Archive is an archive found by List.
+type Archive struct {
+	Path     string
+	Name     string
+	Size     int64
+	Manifest *Manifest
+}
+
+// List returns the archives in dir, oldest first. Files that are not
+// readable archives are skipped.
+func List(dir string) ([]Archive, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "goatflow-*.tar"))
+	if err != nil {
+		return nil, err
+	}
+	archives := make([]Archive, 0, len(paths))
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		m, err := ReadManifest(path)
+		if err != nil {
+			continue
+		}
+		archives = append(archives, Archive{Path: path, Name: filepath.Base(path), Size: info.Size(), Manifest: m})
+	}
+	sort.Slice(archives, func(i, j int) bool {
+		return archives[i].Manifest.CreatedAt.Before(archives[j].Manifest.CreatedAt)
+	})
+	return archives, nil
+}
+
+// Latest returns the newest archive in dir, or nil when there is none.
+// With kind set only archives of that kind are considered.
+func Latest(dir, kind string) (*Archive, error) {
+	archives, err := List(dir)
+	if err != nil {
+		return nil, err
+	}
+	for i := len(archives) - 1; i >= 0; i-- {
+		if kind == "" || archives[i].Manifest.Kind == kind {
+			return &archives[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// Prune removes the archives in dir created before cutoff and returns their
+// names. Archives that a kept archive takes content from are kept, and so
+// is the newest archive.
+func Prune(dir string, cutoff time.Time) ([]string, error) {
+	archives, err := List(dir)
+	if err != nil {
+		return nil, err
+	}
+	needed := make(map[string]bool)
+	for i, a := range archives {
+		if !a.Manifest.CreatedAt.Before(cutoff) || i == len(archives)-1 {
+			needed[a.Name] = true
+			for _, name := range referencedArchives(a.Manifest) {
+				needed[name] = true
+			}
+		}
+	}
+
+	var removed []string
+	for _, a := range archives {
+		if needed[a.Name] {
+			continue
+		}
+		if err := os.Remove(a.Path); err != nil {
+			return removed, err
+		}
+		removed = append(removed, a.Name)
+	}
+	return removed, nil
+}
+
+// referencedArchives returns the names of the other archives m takes
+// content from.
+func referencedArchives(m *Manifest) []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, e := range m.Entries {
+		if e.Archive != "" && !seen[e.Archive] {
+			seen[e.Archive] = true
+			names = append(names, e.Archive)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// cleanEntryPath reports whether p is a relative path that stays inside
+// the directory it is restored to.
+func cleanEntryPath(p string) bool {
+	if p == "" || strings.HasPrefix(p, "/") || strings.Contains(p, `\`) {
+		return false
+	}
+	for _, part := range strings.Split(p, "/") {
+		if part == "" || part == "." || part == ".." {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,141 @@
+package backup
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/testutil"
+)
+
+func TestCreateAndRestore(t *testing.T) {
+	db := testutil.MigratedDB(t)
+	ctx := context.Background()
+	dir := t.TempDir()
+	sources := Sources{Attachments: t.TempDir(), Plugins: t.TempDir()}
+	require.NoError(t, os.MkdirAll(filepath.Join(sources.Attachments, "2026", "10"), 0750))
+	require.NoError(t, os.WriteFile(filepath.Join(sources.Attachments, "2026", "10", "1.eml"), []byte("Subject: printer"), 0640))
+	require.NoError(t, os.WriteFile(filepath.Join(sources.Plugins, "stats.wasm"), []byte("\x00asm"), 0755))
+
+	_, err := db.Exec(`INSERT INTO users (id, login, pw, first_name, last_name, valid_id, create_time, create_by, change_time, change_by)
+		VALUES (2, 'alice', 'x', 'Alice', 'Agent', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`)
+	require.NoError(t, err)
+
+	t0 := time.Date(2026, 10, 16, 1, 0, 0, 0, time.UTC)
+	opts := Options{Dir: dir, Driver: "sqlite", Sources: sources, AppVersion: "test", Now: func() time.Time { return t0 }}
+	fullPath, full, err := Create(ctx, db, opts)
+	require.NoError(t, err)
+	assert.Equal(t, "goatflow-20261016T010000Z-full.tar", filepath.Base(fullPath))
+	assert.Equal(t, KindFull, full.Kind)
+	assert.NotZero(t, full.SchemaVersion)
+	sections := map[string]int{}
+	for _, e := range full.Entries {
+		sections[e.Section]++
+		assert.Empty(t, e.Archive)
+	}
+	assert.Equal(t, 1, sections[SectionAttachments])
+	assert.Equal(t, 1, sections[SectionPlugins])
+	assert.NotZero(t, sections[SectionSysconfig], "sysconfig tables are their own section")
+	assert.NotZero(t, sections[SectionDatabase])
+
+	// The incremental backup stores the changed table and the new file only
+	_, err = db.Exec(`UPDATE users SET login = 'mallory' WHERE id = 2`)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(sources.Attachments, "2026", "10", "2.eml"), []byte("Subject: toner"), 0640))
+	opts.Base = fullPath
+	opts.Now = func() time.Time { return t0.Add(time.Hour) }
+	incrPath, incr, err := Create(ctx, db, opts)
+	require.NoError(t, err)
+	assert.Equal(t, KindIncremental, incr.Kind)
+	assert.Equal(t, filepath.Base(fullPath), incr.Base)
+	held := map[string]string{}
+	for _, e := range incr.Entries {
+		held[e.Path] = e.Archive
+	}
+	assert.Empty(t, held["database/users.jsonl"])
+	assert.Equal(t, filepath.Base(fullPath), held["database/valid.jsonl"])
+	assert.Equal(t, filepath.Base(fullPath), held["attachments/2026/10/1.eml"])
+	assert.Empty(t, held["attachments/2026/10/2.eml"])
+	assert.Equal(t, filepath.Base(fullPath), held["plugins/stats.wasm"])
+
+	_, err = Verify(incrPath)
+	require.NoError(t, err)
+
+	// Restore replaces the rows and writes the files back
+	_, err = db.Exec(`DELETE FROM users WHERE id = 2`)
+	require.NoError(t, err)
+	target := Sources{Attachments: t.TempDir(), Plugins: t.TempDir()}
+	_, err = Restore(ctx, db, incrPath, RestoreOptions{Driver: "sqlite", Sources: target})
+	require.NoError(t, err)
+	var login string
+	require.NoError(t, db.QueryRow(`SELECT login FROM users WHERE id = 2`).Scan(&login))
+	assert.Equal(t, "mallory", login)
+	data, err := os.ReadFile(filepath.Join(target.Attachments, "2026", "10", "1.eml"))
+	require.NoError(t, err)
+	assert.Equal(t, "Subject: printer", string(data))
+	info, err := os.Stat(filepath.Join(target.Plugins, "stats.wasm"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0755), info.Mode().Perm())
+
+	_, err = Restore(ctx, db, incrPath, RestoreOptions{Driver: "mysql", Sources: target})
+	assert.ErrorIs(t, err, ErrDriver)
+	_, err = db.Exec(`UPDATE schema_migrations SET version = version + 1`)
+	require.NoError(t, err)
+	_, err = Restore(ctx, db, incrPath, RestoreOptions{Driver: "sqlite", Sources: target})
+	assert.ErrorIs(t, err, ErrSchemaVersion)
+	_, err = db.Exec(`UPDATE schema_migrations SET version = version - 1`)
+	require.NoError(t, err)
+
+	// A corrupted base fails verification of the incremental backup
+	raw, err := os.ReadFile(fullPath)
+	require.NoError(t, err)
+	corrupt := append([]byte(nil), raw...)
+	corrupt[512+40] ^= 0xff // inside the first member's data
+	require.NoError(t, os.WriteFile(fullPath, corrupt, 0600))
+	_, err = Verify(incrPath)
+	assert.ErrorIs(t, err, ErrChecksum)
+	require.NoError(t, os.Remove(fullPath))
+	_, err = Verify(incrPath)
+	assert.ErrorIs(t, err, ErrMissingBase)
+	require.NoError(t, os.WriteFile(fullPath, raw, 0600))
+}
+
+func TestPrune(t *testing.T) {
+	db := testutil.MigratedDB(t)
+	ctx := context.Background()
+	dir := t.TempDir()
+	t0 := time.Date(2026, 10, 1, 1, 0, 0, 0, time.UTC)
+	create := func(at time.Time, base string) string {
+		path, _, err := Create(ctx, db, Options{Dir: dir, Driver: "sqlite", Base: base, Now: func() time.Time { return at }})
+		require.NoError(t, err)
+		return filepath.Base(path)
+	}
+	full1 := create(t0, "")
+	incr1 := create(t0.AddDate(0, 0, 1), filepath.Join(dir, full1))
+	full2 := create(t0.AddDate(0, 0, 7), "")
+	incr2 := create(t0.AddDate(0, 0, 8), filepath.Join(dir, full2))
+
+	latest, err := Latest(dir, KindFull)
+	require.NoError(t, err)
+	assert.Equal(t, full2, latest.Name)
+
+	// The cutoff falls after full2, which incr2 still needs
+	removed, err := Prune(dir, t0.AddDate(0, 0, 7).Add(time.Hour))
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{full1, incr1}, removed)
+	archives, err := List(dir)
+	require.NoError(t, err)
+	require.Len(t, archives, 2)
+	assert.Equal(t, full2, archives[0].Name)
+	assert.Equal(t, incr2, archives[1].Name)
+
+	// The newest archive is always kept
+	removed, err = Prune(dir, t0.AddDate(1, 0, 0))
+	require.NoError(t, err)
+	assert.Empty(t, removed)
+}
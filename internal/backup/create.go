@@ -0,0 +1,276 @@
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Options configures Create.
+type Options struct {
+	// Dir is the directory the archive is written to.
+	Dir string
+	// Driver is the database driver: mysql, postgres or sqlite.
+	Driver string
+	// Sources are the directories backed up next to the database.
+	Sources Sources
+	// Base is the archive an incremental backup is based on. It must be in
+	// Dir. Empty creates a full backup.
+	Base string
+	// AppVersion is recorded in the manifest.
+	AppVersion string
+	// Now returns the creation time; time.Now when nil.
+	Now func() time.Time
+}
+
+// Create writes a backup archive to opts.Dir and returns its path. The
+// archive only appears under its final name once it is complete.
+func Create(ctx context.Context, db *sql.DB, opts Options) (string, *Manifest, error) {
+	now := time.Now
+	if opts.Now != nil {
+		now = opts.Now
+	}
+	m := &Manifest{
+		Format:     FormatVersion,
+		Kind:       KindFull,
+		CreatedAt:  now().UTC(),
+		AppVersion: opts.AppVersion,
+		Driver:     dialect(opts.Driver),
+		Entries:    make([]Entry, 0),
+	}
+
+	var base map[string]Entry
+	if opts.Base != "" {
+		if filepath.Clean(filepath.Dir(opts.Base)) != filepath.Clean(opts.Dir) {
+			return "", nil, fmt.Errorf("base archive %s must be in %s", opts.Base, opts.Dir)
+		}
+		baseManifest, err := ReadManifest(opts.Base)
+		if err != nil {
+			return "", nil, err
+		}
+		if baseManifest.Driver != m.Driver {
+			return "", nil, fmt.Errorf("%w: base archive is from %s", ErrDriver, baseManifest.Driver)
+		}
+		m.Kind, m.Base = KindIncremental, filepath.Base(opts.Base)
+		base = make(map[string]Entry, len(baseManifest.Entries))
+		for _, e := range baseManifest.Entries {
+			if e.Archive == "" {
+				e.Archive = m.Base
+			}
+			base[e.Path] = e
+		}
+	}
+
+	if err := os.MkdirAll(opts.Dir, 0750); err != nil {
+		return "", nil, err
+	}
+	path := filepath.Join(opts.Dir, archiveName(m.CreatedAt, m.Kind))
+	partial := path + ".partial"
+	f, err := os.OpenFile(partial, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return "", nil, err
+	}
+	aw := &archiveWriter{dir: opts.Dir, tw: tar.NewWriter(f), m: m, base: base}
+	fail := func(err error) (string, *Manifest, error) {
+		_ = f.Close()
+		_ = os.Remove(partial)
+		return "", nil, err
+	}
+
+	if err := aw.addDatabase(ctx, db); err != nil {
+		return fail(err)
+	}
+	for _, section := range []string{SectionAttachments, SectionPlugins} {
+		if err := aw.addDir(ctx, section, opts.Sources.dir(section)); err != nil {
+			return fail(err)
+		}
+	}
+	if err := aw.writeManifest(); err != nil {
+		return fail(err)
+	}
+	if err := aw.tw.Close(); err != nil {
+		return fail(err)
+	}
+	if err := f.Sync(); err != nil {
+		return fail(err)
+	}
+	if err := f.Close(); err != nil {
+		_ = os.Remove(partial)
+		return "", nil, err
+	}
+	if err := os.Rename(partial, path); err != nil {
+		_ = os.Remove(partial)
+		return "", nil, err
+	}
+	return path, m, nil
+}
+
+// archiveWriter adds members to an archive and their entries to its
+// manifest.
+type archiveWriter struct {
+	dir  string
+	tw   *tar.Writer
+	m    *Manifest
+	base map[string]Entry
+}
+
+// addDatabase dumps every table inside one read-only transaction, so the
+// dumps are a consistent snapshot.
+func (aw *archiveWriter) addDatabase(ctx context.Context, db *sql.DB) error {
+	var txOpts *sql.TxOptions
+	if aw.m.Driver != "sqlite" {
+		txOpts = &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true}
+	}
+	tx, err := db.BeginTx(ctx, txOpts)
+	if err != nil {
+		return fmt.Errorf("begin snapshot: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if aw.m.SchemaVersion, err = schemaVersion(ctx, tx); err != nil {
+		return err
+	}
+	tables, err := listTables(ctx, tx, aw.m.Driver)
+	if err != nil {
+		return err
+	}
+	for _, table := range tables {
+		section := sectionOf(table)
+		entry := Entry{Path: section + "/" + table + ".jsonl", Section: section}
+		err := aw.add(entry, func(w io.Writer) error {
+			return dumpTable(ctx, tx, aw.m.Driver, table, w)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// addDir adds the regular files below dir. Files the base archive holds
+// with the same size and modification time are taken from there.
+func (aw *archiveWriter) addDir(ctx context.Context, section, dir string) error {
+	if dir == "" {
+		return nil
+	}
+	if _, err := os.Stat(dir); errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		entry := Entry{
+			Path:    section + "/" + filepath.ToSlash(rel),
+			Section: section,
+			Mode:    int64(info.Mode().Perm()),
+			ModTime: info.ModTime().UTC(),
+		}
+		if prev, ok := aw.base[entry.Path]; ok && prev.Size == info.Size() && prev.ModTime.Equal(entry.ModTime) {
+			entry.Size, entry.SHA256, entry.Archive = prev.Size, prev.SHA256, prev.Archive
+			aw.m.Entries = append(aw.m.Entries, entry)
+			return nil
+		}
+		return aw.add(entry, func(w io.Writer) error {
+			src, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer src.Close()
+			_, err = io.Copy(w, src)
+			return err
+		})
+	})
+}
+
+// add compresses what write produces into a staging file and, unless the
+// base archive holds the same content, copies it into the archive.
+func (aw *archiveWriter) add(entry Entry, write func(io.Writer) error) error {
+	stage, err := os.CreateTemp(aw.dir, ".stage-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = stage.Close()
+		_ = os.Remove(stage.Name())
+	}()
+
+	sum := sha256.New()
+	counter := &countWriter{}
+	gz := gzip.NewWriter(stage)
+	if err := write(io.MultiWriter(gz, sum, counter)); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	entry.Size, entry.SHA256 = counter.n, hex.EncodeToString(sum.Sum(nil))
+
+	if prev, ok := aw.base[entry.Path]; ok && prev.SHA256 == entry.SHA256 {
+		entry.Archive = prev.Archive
+		aw.m.Entries = append(aw.m.Entries, entry)
+		return nil
+	}
+
+	size, err := stage.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return err
+	}
+	if _, err := stage.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	hdr := &tar.Header{Name: entry.Path, Mode: 0600, Size: size, ModTime: aw.m.CreatedAt, Typeflag: tar.TypeReg}
+	if err := aw.tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	if _, err := io.Copy(aw.tw, stage); err != nil {
+		return err
+	}
+	aw.m.Entries = append(aw.m.Entries, entry)
+	return nil
+}
+
+func (aw *archiveWriter) writeManifest() error {
+	data, err := json.MarshalIndent(aw.m, "", "  ")
+	if err != nil {
+		return err
+	}
+	hdr := &tar.Header{Name: manifestName, Mode: 0600, Size: int64(len(data)), ModTime: aw.m.CreatedAt, Typeflag: tar.TypeReg}
+	if err := aw.tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err = aw.tw.Write(data)
+	return err
+}
+
+type countWriter struct{ n int64 }
+
+func (w *countWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
@@ -0,0 +1,248 @@
+package backup
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// skippedTables are not backed up: the migration bookkeeping is described
+// by the manifest's schema version, and queued jobs belong to the running
+// installation, not to its data.
+var skippedTables = map[string]bool{
+	"schema_migrations":        true,
+	"schema_migration_history": true,
+	"job_queue":                true,
+}
+
+// dialect returns the database family of a driver name.
+func dialect(driver string) string {
+	switch strings.ToLower(driver) {
+	case "mysql", "mariadb":
+		return "mysql"
+	case "postgres", "postgresql", "pgx":
+		return "postgres"
+	case "sqlite", "sqlite3":
+		return "sqlite"
+	}
+	return strings.ToLower(driver)
+}
+
+// quoteIdent quotes a table or column name.
+func quoteIdent(dialect, name string) string {
+	if dialect == "mysql" {
+		return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+	}
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// placeholders returns n bind parameters.
+func placeholders(dialect string, n int) string {
+	ph := make([]string, n)
+	for i := range ph {
+		if dialect == "postgres" {
+			ph[i] = fmt.Sprintf("$%d", i+1)
+		} else {
+			ph[i] = "?"
+		}
+	}
+	return strings.Join(ph, ", ")
+}
+
+// sectionOf returns the archive section a table belongs to.
+func sectionOf(table string) string {
+	if strings.HasPrefix(table, "sysconfig_") {
+		return SectionSysconfig
+	}
+	return SectionDatabase
+}
+
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// listTables returns the tables to back up, sorted by name.
+func listTables(ctx context.Context, q queryer, dialect string) ([]string, error) {
+	var query string
+	switch dialect {
+	case "mysql":
+		query = "SELECT table_name FROM information_schema.tables WHERE table_schema = DATABASE() AND table_type = 'BASE TABLE'"
+	case "postgres":
+		query = "SELECT tablename FROM pg_tables WHERE schemaname = current_schema()"
+	case "sqlite":
+		query = "SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%'"
+	default:
+		return nil, fmt.Errorf("backup: unsupported database driver %q", dialect)
+	}
+	rows, err := q.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("list tables: %w", err)
+	}
+	defer rows.Close()
+
+	var tables []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		if !skippedTables[strings.ToLower(name)] {
+			tables = append(tables, name)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	sort.Strings(tables)
+	return tables, nil
+}
+
+// schemaVersion returns the migration version of the database.
+func schemaVersion(ctx context.Context, q queryer) (uint, error) {
+	var version int64
+	var dirty bool
+	err := q.QueryRowContext(ctx, "SELECT version, dirty FROM schema_migrations LIMIT 1").Scan(&version, &dirty)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("read schema version: %w", err)
+	}
+	if dirty {
+		return 0, fmt.Errorf("%w at version %d, fix it with `gk db migrate force`", ErrDirtySchema, version)
+	}
+	return uint(version), nil
+}
+
+// tableHeader is the first line of a table dump. Each following line is a
+// JSON array with one row's values in column order.
+type tableHeader struct {
+	Table   string   `json:"table"`
+	Columns []string `json:"columns"`
+}
+
+// dumpTable writes all rows of table to w.
+func dumpTable(ctx context.Context, q queryer, dialect, table string, w io.Writer) error {
+	rows, err := q.QueryContext(ctx, "SELECT * FROM "+quoteIdent(dialect, table))
+	if err != nil {
+		return fmt.Errorf("dump %s: %w", table, err)
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(w)
+	if err := enc.Encode(tableHeader{Table: table, Columns: columns}); err != nil {
+		return err
+	}
+	values := make([]any, len(columns))
+	ptrs := make([]any, len(columns))
+	for i := range values {
+		ptrs[i] = &values[i]
+	}
+	row := make([]any, len(columns))
+	for rows.Next() {
+		if err := rows.Scan(ptrs...); err != nil {
+			return fmt.Errorf("dump %s: %w", table, err)
+		}
+		for i, v := range values {
+			row[i] = encodeValue(v)
+		}
+		if err := enc.Encode(row); err != nil {
+			return err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("dump %s: %w", table, err)
+	}
+	return nil
+}
+
+// Values that JSON cannot tell apart from strings are wrapped: bytes as
+// {"b": base64} and times as {"t": RFC 3339}.
+type (
+	bytesValue struct {
+		B string `json:"b"`
+	}
+	timeValue struct {
+		T string `json:"t"`
+	}
+)
+
+func encodeValue(v any) any {
+	switch v := v.(type) {
+	case []byte:
+		return bytesValue{B: base64.StdEncoding.EncodeToString(v)}
+	case time.Time:
+		return timeValue{T: v.Format(time.RFC3339Nano)}
+	}
+	return v
+}
+
+func decodeValue(v any) (any, error) {
+	switch v := v.(type) {
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n, nil
+		}
+		return v.Float64()
+	case map[string]any:
+		if s, ok := v["b"].(string); ok {
+			return base64.StdEncoding.DecodeString(s)
+		}
+		if s, ok := v["t"].(string); ok {
+			return time.Parse(time.RFC3339Nano, s)
+		}
+		return nil, fmt.Errorf("unknown value %v", v)
+	}
+	return v, nil
+}
+
+// tableReader reads a table dump written by dumpTable.
+type tableReader struct {
+	dec    *json.Decoder
+	header tableHeader
+}
+
+func newTableReader(r io.Reader) (*tableReader, error) {
+	dec := json.NewDecoder(bufio.NewReader(r))
+	dec.UseNumber()
+	tr := &tableReader{dec: dec}
+	if err := dec.Decode(&tr.header); err != nil {
+		return nil, fmt.Errorf("read table header: %w", err)
+	}
+	if tr.header.Table == "" || len(tr.header.Columns) == 0 {
+		return nil, fmt.Errorf("%w: table dump has no header", ErrFormat)
+	}
+	return tr, nil
+}
+
+// next returns the values of the next row, or io.EOF after the last one.
+func (tr *tableReader) next() ([]any, error) {
+	var raw []any
+	if err := tr.dec.Decode(&raw); err != nil {
+		return nil, err
+	}
+	if len(raw) != len(tr.header.Columns) {
+		return nil, fmt.Errorf("%w: %s row has %d values for %d columns",
+			ErrFormat, tr.header.Table, len(raw), len(tr.header.Columns))
+	}
+	for i, v := range raw {
+		decoded, err := decodeValue(v)
+		if err != nil {
+			return nil, fmt.Errorf("%s.%s: %w", tr.header.Table, tr.header.Columns[i], err)
+		}
+		raw[i] = decoded
+	}
+	return raw, nil
+}
@@ -0,0 +1,336 @@
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// RestoreOptions configures Restore.
+type RestoreOptions struct {
+	// Driver is the database driver: mysql, postgres or sqlite.
+	Driver string
+	// Sources are the directories files are restored to. Files of a
+	// section whose directory is empty are not restored.
+	Sources Sources
+}
+
+// Verify checks that every entry of the archive at path, including those
+// held by other archives in its directory, is present and has its recorded
+// size and checksum.
+func Verify(path string) (*Manifest, error) {
+	m, err := ReadManifest(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkEntries(m); err != nil {
+		return nil, err
+	}
+	err = forEachEntry(path, m, nil, func(Entry, io.Reader) error { return nil })
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Restore verifies the archive at path and then replaces the rows of the
+// backed-up tables with the snapshot, in one transaction, and writes the
+// backed-up files back to their directories. The database must be at the
+// schema version the backup was taken at. Tables and files that are not in
+// the backup are left alone.
+func Restore(ctx context.Context, db *sql.DB, path string, opts RestoreOptions) (*Manifest, error) {
+	m, err := ReadManifest(path)
+	if err != nil {
+		return nil, err
+	}
+	if d := dialect(opts.Driver); m.Driver != d {
+		return nil, fmt.Errorf("%w: the backup is from %s, the database is %s", ErrDriver, m.Driver, d)
+	}
+	current, err := schemaVersion(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	if current != m.SchemaVersion {
+		return nil, fmt.Errorf("%w: the backup is at version %d, the database at %d; migrate the database to version %d first",
+			ErrSchemaVersion, m.SchemaVersion, current, m.SchemaVersion)
+	}
+	if _, err := Verify(path); err != nil {
+		return nil, err
+	}
+
+	if err := restoreDatabase(ctx, db, m.Driver, path, m); err != nil {
+		return nil, err
+	}
+	for _, section := range []string{SectionAttachments, SectionPlugins} {
+		if err := restoreDir(path, m, section, opts.Sources.dir(section)); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// checkEntries rejects manifests whose paths could escape the directories
+// they are restored to.
+func checkEntries(m *Manifest) error {
+	for _, e := range m.Entries {
+		if !cleanEntryPath(e.Path) || !strings.HasPrefix(e.Path, e.Section+"/") {
+			return fmt.Errorf("%w: invalid entry path %q", ErrFormat, e.Path)
+		}
+		if e.Archive != "" && (e.Archive != filepath.Base(e.Archive) || !strings.HasSuffix(e.Archive, ".tar")) {
+			return fmt.Errorf("%w: invalid archive name %q", ErrFormat, e.Archive)
+		}
+	}
+	return nil
+}
+
+// forEachEntry calls fn with the uncompressed content of every entry of m
+// in one of sections, or of all entries when sections is nil, reading them
+// from the archive at path and the archives next to it that hold them.
+// Each entry's size and checksum are verified after fn has read it.
+func forEachEntry(path string, m *Manifest, sections []string, fn func(Entry, io.Reader) error) error {
+	byArchive := make(map[string]map[string]Entry)
+	var order []string
+	for _, e := range m.Entries {
+		if sections != nil && !contains(sections, e.Section) {
+			continue
+		}
+		if _, ok := byArchive[e.Archive]; !ok {
+			byArchive[e.Archive] = make(map[string]Entry)
+			order = append(order, e.Archive)
+		}
+		byArchive[e.Archive][e.Path] = e
+	}
+
+	for _, name := range order {
+		archivePath := path
+		if name != "" {
+			archivePath = filepath.Join(filepath.Dir(path), name)
+		}
+		wanted := byArchive[name]
+		if err := readArchive(archivePath, wanted, fn); err != nil {
+			if name != "" && errors.Is(err, fs.ErrNotExist) {
+				return fmt.Errorf("%w: %s", ErrMissingBase, name)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// readArchive calls fn for the members of the archive at path listed in
+// wanted and verifies them.
+func readArchive(path string, wanted map[string]Entry, fn func(Entry, io.Reader) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	seen := make(map[string]bool, len(wanted))
+	tr := tar.NewReader(f)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("%w: %s: %v", ErrFormat, filepath.Base(path), err)
+		}
+		entry, ok := wanted[hdr.Name]
+		if !ok || seen[hdr.Name] {
+			continue
+		}
+		seen[hdr.Name] = true
+
+		gz, err := gzip.NewReader(tr)
+		if err != nil {
+			return fmt.Errorf("%w: %s: %v", ErrChecksum, entry.Path, err)
+		}
+		sum := sha256.New()
+		counter := &countWriter{}
+		r := io.TeeReader(gz, io.MultiWriter(sum, counter))
+		if err := fn(entry, r); err != nil {
+			return err
+		}
+		if _, err := io.Copy(io.Discard, r); err != nil {
+			return fmt.Errorf("%w: %s: %v", ErrChecksum, entry.Path, err)
+		}
+		if counter.n != entry.Size || hex.EncodeToString(sum.Sum(nil)) != entry.SHA256 {
+			return fmt.Errorf("%w: %s in %s", ErrChecksum, entry.Path, filepath.Base(path))
+		}
+	}
+	for p := range wanted {
+		if !seen[p] {
+			return fmt.Errorf("%w: %s is missing from %s", ErrChecksum, p, filepath.Base(path))
+		}
+	}
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// restoreDatabase replaces the rows of every table in the backup inside
+// one transaction. Tables are restored in name order, so foreign keys are
+// not enforced meanwhile; the snapshot satisfies them as the source did.
+func restoreDatabase(ctx context.Context, db *sql.DB, d, path string, m *Manifest) error {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// The session settings are switched back before the connection returns
+	// to the pool.
+	switch d {
+	case "mysql":
+		if _, err := conn.ExecContext(ctx, "SET FOREIGN_KEY_CHECKS = 0"); err != nil {
+			return err
+		}
+		defer func() { _, _ = conn.ExecContext(context.Background(), "SET FOREIGN_KEY_CHECKS = 1") }()
+	case "sqlite":
+		if _, err := conn.ExecContext(ctx, "PRAGMA foreign_keys = OFF"); err != nil {
+			return err
+		}
+		defer func() { _, _ = conn.ExecContext(context.Background(), "PRAGMA foreign_keys = ON") }()
+	}
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if d == "postgres" {
+		if _, err := tx.ExecContext(ctx, "SET LOCAL session_replication_role = replica"); err != nil {
+			return fmt.Errorf("disable foreign key triggers (restore needs a role that may set session_replication_role): %w", err)
+		}
+	}
+
+	tables, err := listTables(ctx, tx, d)
+	if err != nil {
+		return err
+	}
+	existing := make(map[string]bool, len(tables))
+	for _, t := range tables {
+		existing[t] = true
+	}
+
+	err = forEachEntry(path, m, []string{SectionDatabase, SectionSysconfig}, func(e Entry, r io.Reader) error {
+		tr, err := newTableReader(r)
+		if err != nil {
+			return fmt.Errorf("%s: %w", e.Path, err)
+		}
+		if !existing[tr.header.Table] {
+			return fmt.Errorf("table %s of the backup does not exist in the database", tr.header.Table)
+		}
+		return restoreTable(ctx, tx, d, tr)
+	})
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func restoreTable(ctx context.Context, tx *sql.Tx, d string, tr *tableReader) error {
+	table := quoteIdent(d, tr.header.Table)
+	if _, err := tx.ExecContext(ctx, "DELETE FROM "+table); err != nil {
+		return fmt.Errorf("clear %s: %w", tr.header.Table, err)
+	}
+
+	columns := make([]string, len(tr.header.Columns))
+	hasID := false
+	for i, c := range tr.header.Columns {
+		columns[i] = quoteIdent(d, c)
+		hasID = hasID || c == "id"
+	}
+	stmt, err := tx.PrepareContext(ctx, fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)",
+		table, strings.Join(columns, ", "), placeholders(d, len(columns))))
+	if err != nil {
+		return fmt.Errorf("restore %s: %w", tr.header.Table, err)
+	}
+	defer stmt.Close()
+	for {
+		row, err := tr.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := stmt.ExecContext(ctx, row...); err != nil {
+			return fmt.Errorf("restore %s: %w", tr.header.Table, err)
+		}
+	}
+
+	// Explicit IDs do not advance PostgreSQL sequences.
+	if d == "postgres" && hasID {
+		var seq sql.NullString
+		if err := tx.QueryRowContext(ctx, "SELECT pg_get_serial_sequence($1, 'id')", tr.header.Table).Scan(&seq); err != nil {
+			return err
+		}
+		if seq.Valid {
+			_, err := tx.ExecContext(ctx, "SELECT setval($1, COALESCE((SELECT MAX(id) FROM "+table+"), 0) + 1, false)", seq.String)
+			if err != nil {
+				return fmt.Errorf("reset sequence of %s: %w", tr.header.Table, err)
+			}
+		}
+	}
+	return nil
+}
+
+// restoreDir writes the files of section to dir, each through a temporary
+// file that replaces the old one only when complete.
+func restoreDir(path string, m *Manifest, section, dir string) error {
+	if dir == "" {
+		return nil
+	}
+	return forEachEntry(path, m, []string{section}, func(e Entry, r io.Reader) error {
+		dest := filepath.Join(dir, filepath.FromSlash(strings.TrimPrefix(e.Path, section+"/")))
+		if err := os.MkdirAll(filepath.Dir(dest), 0750); err != nil {
+			return err
+		}
+		tmp, err := os.CreateTemp(filepath.Dir(dest), ".restore-*")
+		if err != nil {
+			return err
+		}
+		defer func() { _ = os.Remove(tmp.Name()) }()
+		if _, err := io.Copy(tmp, r); err != nil {
+			_ = tmp.Close()
+			return err
+		}
+		if err := tmp.Close(); err != nil {
+			return err
+		}
+		mode := fs.FileMode(e.Mode).Perm()
+		if mode == 0 {
+			mode = 0640
+		}
+		if err := os.Chmod(tmp.Name(), mode); err != nil {
+			return err
+		}
+		if err := os.Rename(tmp.Name(), dest); err != nil {
+			return err
+		}
+		if !e.ModTime.IsZero() {
+			_ = os.Chtimes(dest, time.Now(), e.ModTime)
+		}
+		return nil
+	})
+}
@@ -34,6 +34,9 @@ type Config struct {
 	Maintenance  MaintenanceConfig  `mapstructure:"maintenance"`
 	Integrations IntegrationsConfig `mapstructure:"integrations"`
 	Runner       RunnerConfig       `mapstructure:"runner"`
+	Jobs         JobsConfig         `mapstructure:"jobs"`
+	Plugins      PluginsConfig      `mapstructure:"plugins"`
+	Coordination CoordinationConfig `mapstructure:"coordination"`
 }
 
 type AppConfig struct {
@@ -43,6 +46,9 @@ type AppConfig struct {
 	Debug    bool   `mapstructure:"debug"`
 	Timezone string `mapstructure:"timezone"`
 	DemoMode bool   `mapstructure:"demo_mode"`
+	// BaseURL is the public URL of the web frontend, used for links in
+	// emails such as satisfaction surveys.
+	BaseURL string `mapstructure:"base_url"`
 }
 
 type ServerConfig struct {
@@ -53,6 +59,58 @@ type ServerConfig struct {
 	ShutdownTimeout time.Duration `mapstructure:"shutdown_timeout"`
 	CORS            CORSConfig    `mapstructure:"cors"`
 	Swagger         SwaggerConfig `mapstructure:"swagger"`
+	// TrustedProxies lists proxy IPs/CIDRs whose forwarding headers are
+	// trusted when resolving the client IP.
+	TrustedProxies  []string             `mapstructure:"trusted_proxies"`
+	RemoteIPHeaders []string             `mapstructure:"remote_ip_headers"`
+	Idempotency     IdempotencyConfig    `mapstructure:"idempotency"`
+	RequestCapture  RequestCaptureConfig `mapstructure:"request_capture"`
+	CSRF            CSRFConfig           `mapstructure:"csrf"`
+	GraphQL         GraphQLConfig        `mapstructure:"graphql"`
+	GRPC            GRPCConfig           `mapstructure:"grpc"`
+}
+
+// GRPCConfig controls the gRPC ticket ingestion server, which listens on a
+// port of its own next to the HTTP server.
+type GRPCConfig struct {
+	Enabled         bool   `mapstructure:"enabled"`
+	Port            int    `mapstructure:"port"`              // 0 means 50051
+	MaxMessageBytes int    `mapstructure:"max_message_bytes"` // 0 means 4 MiB
+	TLSCertFile     string `mapstructure:"tls_cert_file"`     // Plaintext when empty
+	TLSKeyFile      string `mapstructure:"tls_key_file"`
+}
+
+// GraphQLConfig controls the read-only GraphQL API at /api/graphql.
+type GraphQLConfig struct {
+	Enabled    bool `mapstructure:"enabled"`
+	MaxDepth   int  `mapstructure:"max_depth"`   // Deepest field nesting allowed; 0 means 10
+	MaxFields  int  `mapstructure:"max_fields"`  // Fields a query may select with fragments expanded; 0 means 500
+	MaxAliases int  `mapstructure:"max_aliases"` // Aliased fields a query may select; 0 means 50
+}
+
+// RequestCaptureConfig controls recording API requests for replay.
+type RequestCaptureConfig struct {
+	Enabled      bool          `mapstructure:"enabled"`
+	Retention    time.Duration `mapstructure:"retention"`      // How long captured requests are kept; 0 means 7 days
+	MaxBodyBytes int           `mapstructure:"max_body_bytes"` // Stored bodies are cut off here; 0 means 64 KiB
+	RedactFields []string      `mapstructure:"redact_fields"`  // Extra field names to redact
+	// ReplayTargets maps target names to base URLs captured requests can
+	// be replayed against.
+	ReplayTargets map[string]string `mapstructure:"replay_targets"`
+	ReplayTimeout time.Duration     `mapstructure:"replay_timeout"`
+}
+
+// CSRFConfig controls the cross-site request forgery check on state-changing
+// requests made with a browser session.
+type CSRFConfig struct {
+	Enabled     bool     `mapstructure:"enabled"`
+	ExemptPaths []string `mapstructure:"exempt_paths"` // Path prefixes that are not checked
+}
+
+// IdempotencyConfig controls Idempotency-Key handling on mutating API routes.
+type IdempotencyConfig struct {
+	Enabled bool          `mapstructure:"enabled"`
+	TTL     time.Duration `mapstructure:"ttl"` // How long responses are replayed; 0 means 24h
 }
 
 // SwaggerConfig controls API documentation exposure.
@@ -83,6 +141,29 @@ type DatabaseConfig struct {
 		AutoMigrate bool   `mapstructure:"auto_migrate"`
 		Path        string `mapstructure:"path"`
 	} `mapstructure:"migrations"`
+	// StatementCacheSize is the number of prepared statements kept per
+	// connection; 0 turns the cache off.
+	StatementCacheSize int `mapstructure:"statement_cache_size"`
+	// SlowQueryThreshold logs statements that take longer, with their
+	// EXPLAIN output when app.debug is on; 0 turns the log off.
+	SlowQueryThreshold time.Duration `mapstructure:"slow_query_threshold"`
+	// Replicas receive read-only queries such as ticket lists, searches and
+	// dashboards while they are no more than ReplicaMaxLag behind.
+	Replicas             []DatabaseReplicaConfig `mapstructure:"replicas"`
+	ReplicaMaxLag        time.Duration           `mapstructure:"replica_max_lag"`
+	ReplicaCheckInterval time.Duration           `mapstructure:"replica_check_interval"`
+}
+
+// DatabaseReplicaConfig describes a read replica. Empty user, password and
+// name take the primary's values.
+type DatabaseReplicaConfig struct {
+	Name     string `mapstructure:"name"`
+	Host     string `mapstructure:"host"`
+	Port     int    `mapstructure:"port"`
+	User     string `mapstructure:"user"`
+	Password string `mapstructure:"password"`
+	Database string `mapstructure:"database"`
+	SSLMode  string `mapstructure:"ssl_mode"`
 }
 
 type ValkeyConfig struct {
@@ -128,6 +209,83 @@ type AuthConfig struct {
 		RequireSpecial   bool `mapstructure:"require_special"`
 		BcryptCost       int  `mapstructure:"bcrypt_cost"`
 	} `mapstructure:"password"`
+	TwoFactor struct {
+		// RequiredRoles lists roles (agent, admin, customer) that must complete
+		// TOTP or WebAuthn before using the application.
+		RequiredRoles []string `mapstructure:"required_roles"`
+		WebAuthn      struct {
+			RPID    string   `mapstructure:"rp_id"`
+			RPName  string   `mapstructure:"rp_name"`
+			Origins []string `mapstructure:"origins"`
+		} `mapstructure:"webauthn"`
+	} `mapstructure:"two_factor"`
+	LDAP struct {
+		// Directories are tried in order when a password is not accepted by
+		// the local database.
+		Directories []LDAPDirectoryConfig `mapstructure:"directories"`
+		// SyncSchedule is the cron expression (with seconds) of the
+		// background sync of directories that have sync enabled.
+		SyncSchedule string `mapstructure:"sync_schedule"`
+	} `mapstructure:"ldap"`
+	APITokens struct {
+		// ScopeValidation controls the startup check that every /api/v1
+		// route reachable with an API token declares a scope: warn logs
+		// offending routes, strict refuses to start, off skips the check.
+		ScopeValidation string `mapstructure:"scope_validation"`
+	} `mapstructure:"api_tokens"`
+	PermissionCache struct {
+		Enabled bool `mapstructure:"enabled"`
+		// Backend is memory (per instance) or valkey (shared by all
+		// instances; falls back to memory when Valkey is not configured).
+		Backend string        `mapstructure:"backend"`
+		TTL     time.Duration `mapstructure:"ttl"`
+	} `mapstructure:"permission_cache"`
+}
+
+// LDAPDirectoryConfig describes one LDAP or Active Directory backend.
+type LDAPDirectoryConfig struct {
+	Name     string `mapstructure:"name"`
+	Frontend string `mapstructure:"frontend"` // agent or customer
+	// Type preselects filters and attributes: active_directory, openldap, 389ds.
+	Type          string   `mapstructure:"type"`
+	Host          string   `mapstructure:"host"`
+	FailoverHosts []string `mapstructure:"failover_hosts"`
+	Port          int      `mapstructure:"port"`
+	UseSSL        bool     `mapstructure:"use_ssl"`
+	UseTLS        bool     `mapstructure:"use_tls"`
+	SkipTLSVerify bool     `mapstructure:"skip_tls_verify"`
+	Timeout       int      `mapstructure:"timeout_seconds"`
+	BindDN        string   `mapstructure:"bind_dn"`
+	BindPassword  string   `mapstructure:"bind_password"`
+	// BindPasswordEnv names an environment variable holding the bind password.
+	BindPasswordEnv string `mapstructure:"bind_password_env"`
+	BaseDN          string `mapstructure:"base_dn"`
+	UserBaseDN      string `mapstructure:"user_base_dn"`
+	UserFilter      string `mapstructure:"user_filter"`
+	SyncFilter      string `mapstructure:"sync_filter"`
+	Domain          string `mapstructure:"domain"`
+	Attributes      struct {
+		Login      string `mapstructure:"login"`
+		Email      string `mapstructure:"email"`
+		FirstName  string `mapstructure:"first_name"`
+		LastName   string `mapstructure:"last_name"`
+		CustomerID string `mapstructure:"customer_id"`
+	} `mapstructure:"attributes"`
+	GroupBaseDN    string             `mapstructure:"group_base_dn"`
+	GroupFilter    string             `mapstructure:"group_filter"`
+	GroupAttribute string             `mapstructure:"group_attribute"`
+	GroupMappings  []LDAPGroupMapping `mapstructure:"group_mappings"`
+	// AutoCreate adds unknown users on their first login and during sync.
+	AutoCreate bool `mapstructure:"auto_create"`
+	Sync       bool `mapstructure:"sync"`
+}
+
+// LDAPGroupMapping grants a GoatFlow group permission to members of an
+// LDAP group. Permissions are revoked again when the membership ends.
+type LDAPGroupMapping struct {
+	LDAPGroup  string `mapstructure:"ldap_group"`
+	Group      string `mapstructure:"group"`
+	Permission string `mapstructure:"permission"`
 }
 
 type EmailConfig struct {
@@ -178,9 +336,78 @@ type StorageConfig struct {
 		Endpoint  string `mapstructure:"endpoint"`
 	} `mapstructure:"s3"`
 	Attachments struct {
-		MaxSize      int64    `mapstructure:"max_size"`
-		AllowedTypes []string `mapstructure:"allowed_types"`
+		MaxSize      int64                   `mapstructure:"max_size"`
+		AllowedTypes []string                `mapstructure:"allowed_types"`
+		Scan         AttachmentScanConfig    `mapstructure:"scan"`
+		Preview      AttachmentPreviewConfig `mapstructure:"preview"`
 	} `mapstructure:"attachments"`
+	Compression StorageCompressionConfig `mapstructure:"compression"`
+}
+
+// AttachmentScanConfig configures antivirus scanning of uploaded and
+// inbound attachments.
+type AttachmentScanConfig struct {
+	Enabled bool   `mapstructure:"enabled"`
+	Driver  string `mapstructure:"driver"` // "clamd"
+	// Address is the scanner's socket: "tcp://host:3310" or
+	// "unix:///path/to/clamd.ctl".
+	Address string        `mapstructure:"address"`
+	Timeout time.Duration `mapstructure:"timeout"`
+	// OnError is what happens to an attachment that could not be scanned:
+	// "accept" stores it with scan status error, "quarantine" holds it
+	// for an admin.
+	OnError      string `mapstructure:"on_error"`
+	NotifyAdmins bool   `mapstructure:"notify_admins"` // Email the admin group about infected files
+}
+
+// AttachmentPreviewConfig configures thumbnails and first-page previews
+// of attachments.
+type AttachmentPreviewConfig struct {
+	Enabled bool `mapstructure:"enabled"`
+	// Cache is where generated previews are kept: "disk" or "s3". The s3
+	// cache uses the bucket of the storage.s3 settings.
+	Cache       string                 `mapstructure:"cache"`
+	CachePath   string                 `mapstructure:"cache_path"`   // Directory of the disk cache
+	CachePrefix string                 `mapstructure:"cache_prefix"` // Key prefix in the S3 bucket
+	Converter   PreviewConverterConfig `mapstructure:"converter"`
+}
+
+// PreviewConverterConfig configures how the first page of a document,
+// such as a PDF, is rendered as an image.
+type PreviewConverterConfig struct {
+	// Driver is "vips" to let libvips load the documents, "command" to run
+	// an external program, or empty to show no document previews.
+	Driver string `mapstructure:"driver"`
+	// Command and Args run the external program. It reads the document on
+	// stdin and writes an image of the first page to stdout.
+	Command      string        `mapstructure:"command"`
+	Args         []string      `mapstructure:"args"`
+	ContentTypes []string      `mapstructure:"content_types"` // Document types to convert
+	Timeout      time.Duration `mapstructure:"timeout"`
+}
+
+// StorageCompressionConfig configures compression of article content
+// stored in the database.
+type StorageCompressionConfig struct {
+	ArticleBody       StorageCompressionTable `mapstructure:"article_data_mime"`
+	ArticleAttachment StorageCompressionTable `mapstructure:"article_data_mime_attachment"`
+	// Schedule is the cron expression (with seconds) of the background
+	// recompression job.
+	Schedule      string `mapstructure:"schedule"`
+	BatchSize     int    `mapstructure:"batch_size"`
+	MaxRowsPerRun int    `mapstructure:"max_rows_per_run"`
+}
+
+// StorageCompressionTable holds the compression settings of one table.
+type StorageCompressionTable struct {
+	Enabled    bool   `mapstructure:"enabled"`
+	Codec      string `mapstructure:"codec"`
+	Level      int    `mapstructure:"level"`
+	MinSize    int    `mapstructure:"min_size"`
+	MinAgeDays int    `mapstructure:"min_age_days"`
+	// ContentTypes limits attachment compression to these MIME types
+	// (patterns such as "text/*").
+	ContentTypes []string `mapstructure:"content_types"`
 }
 
 type TicketConfig struct {
@@ -236,18 +463,21 @@ type MetricsConfig struct {
 		Path    string `mapstructure:"path"`
 	} `mapstructure:"prometheus"`
 	OpenTelemetry struct {
-		Enabled     bool    `mapstructure:"enabled"`
-		Endpoint    string  `mapstructure:"endpoint"`
-		ServiceName string  `mapstructure:"service_name"`
-		TraceRatio  float64 `mapstructure:"trace_ratio"`
+		Enabled     bool              `mapstructure:"enabled"`
+		Endpoint    string            `mapstructure:"endpoint"` // OTLP/HTTP collector URL
+		Headers     map[string]string `mapstructure:"headers"`  // Sent with every export, e.g. an API key
+		ServiceName string            `mapstructure:"service_name"`
+		TraceRatio  float64           `mapstructure:"trace_ratio"`
 	} `mapstructure:"opentelemetry"`
 }
 
 type RateLimitingConfig struct {
-	Enabled           bool     `mapstructure:"enabled"`
-	RequestsPerMinute int      `mapstructure:"requests_per_minute"`
-	Burst             int      `mapstructure:"burst"`
-	ExcludePaths      []string `mapstructure:"exclude_paths"`
+	Enabled                  bool     `mapstructure:"enabled"`
+	RequestsPerMinute        int      `mapstructure:"requests_per_minute"`
+	Burst                    int      `mapstructure:"burst"`
+	ExcludePaths             []string `mapstructure:"exclude_paths"`
+	ArchiveSearchPerHour     int      `mapstructure:"archive_search_per_hour"`
+	ArchiveSearchConcurrency int      `mapstructure:"archive_search_concurrency"`
 }
 
 type FeaturesConfig struct {
@@ -288,6 +518,13 @@ type IntegrationsConfig struct {
 		Timeout       time.Duration `mapstructure:"timeout"`
 		RetryAttempts int           `mapstructure:"retry_attempts"`
 	} `mapstructure:"webhook"`
+	// CertificateHook is called to provision and revoke TLS certificates
+	// for customer portal domains.
+	CertificateHook struct {
+		URL     string        `mapstructure:"url"`
+		Secret  string        `mapstructure:"secret"`
+		Timeout time.Duration `mapstructure:"timeout"`
+	} `mapstructure:"certificate_hook"`
 }
 
 // RunnerConfig contains configuration for background task runner.
@@ -297,6 +534,61 @@ type RunnerConfig struct {
 	} `mapstructure:"session_cleanup"`
 }
 
+// JobsConfig configures the background job queue.
+type JobsConfig struct {
+	Enabled      bool          `mapstructure:"enabled"`
+	Driver       string        `mapstructure:"driver"` // "database" or "redis" (uses the valkey connection)
+	Workers      int           `mapstructure:"workers"`
+	Queues       []string      `mapstructure:"queues"` // In order of priority
+	PollInterval time.Duration `mapstructure:"poll_interval"`
+	Lease        time.Duration `mapstructure:"lease"` // How long a job may run before another worker takes it over
+	MaxAttempts  int           `mapstructure:"max_attempts"`
+	KeyPrefix    string        `mapstructure:"key_prefix"` // Redis driver only
+}
+
+// PluginsConfig configures the plugin host.
+type PluginsConfig struct {
+	Cache struct {
+		// Backend is memory (per instance) or valkey (shared by all
+		// instances; falls back to memory when Valkey is not configured).
+		Backend    string `mapstructure:"backend"`
+		MaxEntries int    `mapstructure:"max_entries"` // Memory backend only
+		MaxBytes   int64  `mapstructure:"max_bytes"`   // Memory backend only
+		KeyPrefix  string `mapstructure:"key_prefix"`  // Valkey backend only
+	} `mapstructure:"cache"`
+	// CircuitBreaker short-circuits calls to a plugin whose calls keep
+	// failing. Zero values use the plugin package defaults.
+	CircuitBreaker struct {
+		Enabled     bool          `mapstructure:"enabled"`
+		Window      time.Duration `mapstructure:"window"`       // Span calls and failures are counted over
+		MinCalls    int           `mapstructure:"min_calls"`    // Calls in a window before its rate counts
+		FailureRate float64       `mapstructure:"failure_rate"` // Share of failed calls that opens the breaker
+		OpenFor     time.Duration `mapstructure:"open_for"`     // Until a probe call is let through
+	} `mapstructure:"circuit_breaker"`
+	// WASMPool sizes the pool of module instances of each WASM plugin;
+	// concurrent calls beyond MaxInstances wait for a free instance.
+	WASMPool struct {
+		MinInstances int `mapstructure:"min_instances"` // Created at load
+		MaxInstances int `mapstructure:"max_instances"` // Each has its own memory
+	} `mapstructure:"wasm_pool"`
+	// PublicRoutes grants plugins, by name, the capability to serve routes
+	// marked public without login.
+	PublicRoutes map[string]struct {
+		AllowIPs  []string `mapstructure:"allow_ips"`  // IPs or CIDRs; empty allows any client
+		RateLimit int      `mapstructure:"rate_limit"` // Requests per minute and client for routes without their own limit
+	} `mapstructure:"public_routes"`
+}
+
+// CoordinationConfig configures distributed locks and leader election
+// between replicas.
+type CoordinationConfig struct {
+	// Backend is database, redis (uses the valkey connection) or memory
+	// (a single instance only).
+	Backend   string        `mapstructure:"backend"`
+	Lease     time.Duration `mapstructure:"lease"`      // How long a dead leader's role stays taken
+	KeyPrefix string        `mapstructure:"key_prefix"` // Redis backend only
+}
+
 // Load initializes the configuration with hot reload support.
 func Load(configPath string) error {
 	var err error
@@ -0,0 +1,150 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// ErrNoConfigFile is returned by Reload when LoadFile was not used.
+var ErrNoConfigFile = errors.New("config: no config file loaded")
+
+// configFile is the file given to LoadFile and the directory of its
+// defaults, read again by Reload.
+var configFile struct {
+	dir  string
+	path string
+}
+
+// LoadFile loads the configuration from a YAML or TOML file, chosen by its
+// extension, on top of default.yaml in configDir when that exists.
+// GOATFLOW_* environment variables override both. The result is validated
+// before it is used.
+//
+// LoadFile takes the place of Load: calling Load afterwards keeps this
+// configuration.
+func LoadFile(configDir, path string) error {
+	next, err := readFile(configDir, path)
+	if err != nil {
+		return err
+	}
+	if err := Validate(next); err != nil {
+		return err
+	}
+	once.Do(func() {})
+	mu.Lock()
+	defer mu.Unlock()
+	cfg = next
+	configFile.dir, configFile.path = configDir, path
+	return nil
+}
+
+// ReadFile reads and validates a config file like LoadFile without using it.
+func ReadFile(configDir, path string) (*Config, error) {
+	next, err := readFile(configDir, path)
+	if err != nil {
+		return nil, err
+	}
+	return next, Validate(next)
+}
+
+func readFile(configDir, path string) (*Config, error) {
+	v := viper.New()
+	defaults := filepath.Join(configDir, "default.yaml")
+	if _, err := os.Stat(defaults); err == nil && configDir != "" {
+		v.SetConfigFile(defaults)
+		if err := v.ReadInConfig(); err != nil {
+			return nil, fmt.Errorf("failed to read default config: %w", err)
+		}
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		v.SetConfigType("yaml")
+	case ".toml":
+		v.SetConfigType("toml")
+	default:
+		return nil, fmt.Errorf("config file %s: use a .yaml, .yml or .toml file", path)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	defer f.Close()
+	if err := v.MergeConfig(f); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	v.SetEnvPrefix("GoatFlow")
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
+	v.AutomaticEnv()
+
+	next := &Config{}
+	if err := v.Unmarshal(next); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
+	}
+	return next, nil
+}
+
+// restartSettings are read once at startup. Reload keeps their running
+// values.
+var restartSettings = []struct {
+	name string
+	keep func(next, running *Config) bool
+}{
+	{"server.host", func(n, r *Config) bool { return keep(&n.Server.Host, &r.Server.Host) }},
+	{"server.port", func(n, r *Config) bool { return keep(&n.Server.Port, &r.Server.Port) }},
+	{"server.trusted_proxies", func(n, r *Config) bool { return keep(&n.Server.TrustedProxies, &r.Server.TrustedProxies) }},
+	{"server.remote_ip_headers", func(n, r *Config) bool { return keep(&n.Server.RemoteIPHeaders, &r.Server.RemoteIPHeaders) }},
+	{"server.grpc", func(n, r *Config) bool { return keep(&n.Server.GRPC, &r.Server.GRPC) }},
+	{"database", func(n, r *Config) bool { return keep(&n.Database, &r.Database) }},
+	{"valkey", func(n, r *Config) bool { return keep(&n.Valkey, &r.Valkey) }},
+	{"auth.permission_cache", func(n, r *Config) bool { return keep(&n.Auth.PermissionCache, &r.Auth.PermissionCache) }},
+	{"email", func(n, r *Config) bool { return keep(&n.Email, &r.Email) }},
+	{"metrics", func(n, r *Config) bool { return keep(&n.Metrics, &r.Metrics) }},
+	{"storage", func(n, r *Config) bool { return keep(&n.Storage, &r.Storage) }},
+	{"jobs", func(n, r *Config) bool { return keep(&n.Jobs, &r.Jobs) }},
+	{"runner", func(n, r *Config) bool { return keep(&n.Runner, &r.Runner) }},
+	{"plugins", func(n, r *Config) bool { return keep(&n.Plugins, &r.Plugins) }},
+	{"coordination", func(n, r *Config) bool { return keep(&n.Coordination, &r.Coordination) }},
+}
+
+// keep sets next to the running value and reports whether they differed.
+func keep[T any](next, running *T) bool {
+	changed := !reflect.DeepEqual(*next, *running)
+	*next = *running
+	return changed
+}
+
+// Reload reads the file passed to LoadFile again and swaps in the new
+// configuration. Settings only read at startup keep their running values;
+// the names of those that changed are returned so the caller can ask for a
+// restart. On error the running configuration stays in place.
+func Reload() ([]string, error) {
+	mu.RLock()
+	dir, path, running := configFile.dir, configFile.path, cfg
+	mu.RUnlock()
+	if path == "" {
+		return nil, ErrNoConfigFile
+	}
+	next, err := ReadFile(dir, path)
+	if err != nil {
+		return nil, err
+	}
+	var restart []string
+	if running != nil {
+		for _, s := range restartSettings {
+			if s.keep(next, running) {
+				restart = append(restart, s.name)
+			}
+		}
+	}
+	mu.Lock()
+	cfg = next
+	mu.Unlock()
+	return restart, nil
+}
@@ -0,0 +1,121 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func resetConfig(t *testing.T) {
+	t.Helper()
+	reset := func() {
+		mu.Lock()
+		cfg = nil
+		once = sync.Once{}
+		configFile.dir, configFile.path = "", ""
+		mu.Unlock()
+	}
+	reset()
+	t.Cleanup(reset)
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+}
+
+func TestLoadFileAndReload(t *testing.T) {
+	resetConfig(t)
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "default.yaml"), `
+app:
+  name: GoatFlow
+server:
+  port: 8080
+  shutdown_timeout: 10s
+rate_limiting:
+  enabled: true
+  requests_per_minute: 60
+`)
+	path := filepath.Join(dir, "goatflow.toml")
+	writeFile(t, path, `
+[server]
+port = 9090
+
+[rate_limiting]
+requests_per_minute = 120
+`)
+
+	require.NoError(t, LoadFile(dir, path))
+	assert.Equal(t, "GoatFlow", Get().App.Name, "defaults come from default.yaml")
+	assert.Equal(t, 9090, Get().Server.Port)
+	assert.Equal(t, 10*time.Second, Get().Server.ShutdownTimeout)
+	assert.Equal(t, 120, Get().RateLimiting.RequestsPerMinute)
+
+	// Load keeps the file's configuration
+	require.NoError(t, Load(dir))
+	assert.Equal(t, 9090, Get().Server.Port)
+
+	writeFile(t, path, `
+[server]
+port = 9191
+
+[rate_limiting]
+requests_per_minute = 30
+`)
+	restart, err := Reload()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"server.port"}, restart)
+	assert.Equal(t, 30, Get().RateLimiting.RequestsPerMinute, "hot settings are applied")
+	assert.Equal(t, 9090, Get().Server.Port, "the listen port keeps its running value")
+
+	writeFile(t, path, "[server]\nport = 70000\n")
+	_, err = Reload()
+	assert.ErrorContains(t, err, "server.port")
+	assert.Equal(t, 30, Get().RateLimiting.RequestsPerMinute, "a failed reload keeps the running config")
+}
+
+func TestLoadFileErrors(t *testing.T) {
+	resetConfig(t)
+	dir := t.TempDir()
+
+	_, err := Reload()
+	assert.ErrorIs(t, err, ErrNoConfigFile)
+
+	ini := filepath.Join(dir, "goatflow.ini")
+	writeFile(t, ini, "port=1")
+	assert.ErrorContains(t, LoadFile(dir, ini), "use a .yaml")
+
+	bad := filepath.Join(dir, "goatflow.yaml")
+	writeFile(t, bad, "server:\n  port: [1\n")
+	assert.ErrorContains(t, LoadFile(dir, bad), "failed to parse config file")
+	assert.Nil(t, Get())
+}
+
+func TestValidate(t *testing.T) {
+	valid := func() *Config {
+		c := &Config{}
+		c.Server.Port = 8080
+		return c
+	}
+	assert.NoError(t, Validate(valid()))
+
+	c := valid()
+	c.Server.Port = 0
+	c.Server.ShutdownTimeout = -time.Second
+	c.App.Timezone = "Mars/Olympus"
+	c.Logging.Level = "loud"
+	c.Server.GRPC.Enabled = true
+	c.Server.GRPC.TLSCertFile = "cert.pem"
+	c.RateLimiting.Enabled = true
+	err := Validate(c)
+	require.Error(t, err)
+	for _, key := range []string{"server.port", "server.shutdown_timeout", "app.timezone", "logging.level", "server.grpc", "rate_limiting"} {
+		assert.Contains(t, err.Error(), key)
+	}
+}
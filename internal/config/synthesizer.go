@@ -247,6 +247,16 @@ func (s *Synthesizer) generateVariables(existing map[string]string, rotateOnly b
 		Generated: true,
 	})
 
+	// Never rotated here: stored credentials must be re-encrypted with
+	// `gk secrets rotate` when the master key changes
+	masterKey, _ := s.GenerateSecret(SecretTypeHex, 64, "", "")
+	s.variables = append(s.variables, EnvVariable{
+		Key:       "SECRETS_MASTER_KEY",
+		Value:     s.getOrDefault(existing, "SECRETS_MASTER_KEY", masterKey),
+		Type:      "secret",
+		Generated: true,
+	})
+
 	s.variables = append(s.variables, EnvVariable{Key: "", Value: "", Type: "blank"})
 
 	s.variables = append(s.variables, EnvVariable{
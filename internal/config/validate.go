@@ -0,0 +1,58 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Validate checks the settings the server cannot start or reload with and
+// returns all problems found.
+func Validate(c *Config) error {
+	var errs []error
+	add := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if c.Server.Port < 1 || c.Server.Port > 65535 {
+		add("server.port: %d is not a port number", c.Server.Port)
+	}
+	for name, d := range map[string]time.Duration{
+		"server.read_timeout":     c.Server.ReadTimeout,
+		"server.write_timeout":    c.Server.WriteTimeout,
+		"server.shutdown_timeout": c.Server.ShutdownTimeout,
+	} {
+		if d < 0 {
+			add("%s: must not be negative", name)
+		}
+	}
+	if c.Server.GRPC.Enabled {
+		if c.Server.GRPC.Port < 0 || c.Server.GRPC.Port > 65535 {
+			add("server.grpc.port: %d is not a port number", c.Server.GRPC.Port)
+		}
+		if (c.Server.GRPC.TLSCertFile == "") != (c.Server.GRPC.TLSKeyFile == "") {
+			add("server.grpc: tls_cert_file and tls_key_file must be set together")
+		}
+	}
+	if c.App.Timezone != "" {
+		if _, err := time.LoadLocation(c.App.Timezone); err != nil {
+			add("app.timezone: %v", err)
+		}
+	}
+	if c.Database.Port < 0 || c.Database.Port > 65535 {
+		add("database.port: %d is not a port number", c.Database.Port)
+	}
+	if c.Database.MaxIdleConns > 0 && c.Database.MaxOpenConns > 0 && c.Database.MaxIdleConns > c.Database.MaxOpenConns {
+		add("database.max_idle_conns: %d is more than max_open_conns %d", c.Database.MaxIdleConns, c.Database.MaxOpenConns)
+	}
+	switch strings.ToLower(c.Logging.Level) {
+	case "", "debug", "info", "warn", "warning", "error", "fatal", "panic":
+	default:
+		add("logging.level: unknown level %q", c.Logging.Level)
+	}
+	if c.RateLimiting.Enabled && c.RateLimiting.RequestsPerMinute <= 0 {
+		add("rate_limiting.requests_per_minute: must be positive when rate limiting is enabled")
+	}
+	return errors.Join(errs...)
+}
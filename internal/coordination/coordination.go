@@ -0,0 +1,152 @@
+// Package coordination lets several GoatFlow replicas share work safely.
+//
+// A Locker hands out named, expiring locks. The holder refreshes its lock
+// while it works; when a process dies its lock runs out and another one can
+// take it. An Elector builds leader election on top: of all replicas
+// competing for the same name, one leads at a time, and daemon components
+// that must not run twice, such as the scheduler with its mail fetchers,
+// only run on the leader.
+//
+// Locks are stored in the database, in Valkey/Redis, or, for a single
+// instance and tests, in process memory.
+package coordination
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// Errors returned by lockers.
+var (
+	// ErrNotAcquired is returned by Acquire when another holder has the
+	// lock.
+	ErrNotAcquired = errors.New("lock held by another instance")
+	// ErrLockLost is returned by Refresh when the lock ran out and may
+	// have been taken by another holder.
+	ErrLockLost = errors.New("lock lost")
+)
+
+// Locker hands out named locks that expire unless refreshed.
+type Locker interface {
+	// Acquire takes the named lock for ttl, or returns ErrNotAcquired
+	// when someone else holds it. It does not wait.
+	Acquire(ctx context.Context, name string, ttl time.Duration) (Lock, error)
+	// Holder returns the instance holding the named lock and when the
+	// lock runs out, or "" when nobody holds it.
+	Holder(ctx context.Context, name string) (string, time.Time, error)
+}
+
+// Lock is a held lock.
+type Lock interface {
+	// Name returns the lock name.
+	Name() string
+	// Refresh extends the lock to ttl from now, or returns ErrLockLost.
+	Refresh(ctx context.Context, ttl time.Duration) error
+	// Release gives the lock up. Releasing a lost lock is not an error.
+	Release(ctx context.Context) error
+}
+
+// WithLock runs fn while holding the named lock, refreshing it until fn
+// returns. It returns ErrNotAcquired without running fn when the lock is
+// held elsewhere. fn's context is cancelled if the lock is lost.
+func WithLock(ctx context.Context, locker Locker, name string, ttl time.Duration, fn func(ctx context.Context) error) error {
+	lock, err := locker.Acquire(ctx, name, ttl)
+	if err != nil {
+		return err
+	}
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stop := keepAlive(runCtx, lock, ttl, cancel)
+	err = fn(runCtx)
+	stop()
+	if relErr := lock.Release(context.WithoutCancel(ctx)); relErr != nil && err == nil {
+		err = fmt.Errorf("release lock %s: %w", name, relErr)
+	}
+	return err
+}
+
+// keepAlive refreshes lock every ttl/3 until the returned stop function is
+// called, calling lost once the lock can no longer be held: when Refresh
+// reports ErrLockLost, or when refreshes kept failing for a whole ttl.
+func keepAlive(ctx context.Context, lock Lock, ttl time.Duration, lost func()) (stop func()) {
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		ticker := time.NewTicker(ttl / 3)
+		defer ticker.Stop()
+		heldUntil := time.Now().Add(ttl)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			err := lock.Refresh(ctx, ttl)
+			switch {
+			case err == nil:
+				heldUntil = time.Now().Add(ttl)
+			case ctx.Err() != nil:
+				return
+			case errors.Is(err, ErrLockLost) || time.Now().After(heldUntil):
+				lost()
+				return
+			}
+		}
+	}()
+	return func() {
+		cancel()
+		<-done
+	}
+}
+
+var (
+	instanceID    = newInstanceID()
+	defaultLocker atomic.Pointer[lockerBox]
+)
+
+type lockerBox struct{ Locker }
+
+// InstanceID identifies this process as a lock holder: the host name, the
+// process ID and a random suffix.
+func InstanceID() string {
+	return instanceID
+}
+
+// SetDefault makes l the locker returned by Default.
+func SetDefault(l Locker) {
+	defaultLocker.Store(&lockerBox{l})
+}
+
+// Default returns the application's locker. Until SetDefault is called it
+// is a MemoryLocker, which only coordinates within this process.
+func Default() Locker {
+	if b := defaultLocker.Load(); b != nil {
+		return b.Locker
+	}
+	defaultLocker.CompareAndSwap(nil, &lockerBox{NewMemoryLocker()})
+	return defaultLocker.Load().Locker
+}
+
+func newInstanceID() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "goatflow"
+	}
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), newToken()[:8])
+}
+
+func newToken() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		// crypto/rand does not fail on supported platforms
+		panic(fmt.Sprintf("coordination: generate token: %v", err))
+	}
+	return hex.EncodeToString(b)
+}
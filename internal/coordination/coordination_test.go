@@ -0,0 +1,124 @@
+package coordination
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/testutil"
+)
+
+func newTestDBLocker(t *testing.T) *DBLocker {
+	t.Helper()
+	return NewDBLocker(testutil.MigratedDB(t))
+}
+
+func testLockers(t *testing.T) map[string]Locker {
+	return map[string]Locker{
+		"memory":   NewMemoryLocker(),
+		"database": newTestDBLocker(t),
+	}
+}
+
+func TestLocker_ExclusiveUntilReleased(t *testing.T) {
+	for name, locker := range testLockers(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			lock, err := locker.Acquire(ctx, "scheduler", time.Minute)
+			require.NoError(t, err)
+
+			_, err = locker.Acquire(ctx, "scheduler", time.Minute)
+			assert.ErrorIs(t, err, ErrNotAcquired)
+			holder, _, err := locker.Holder(ctx, "scheduler")
+			require.NoError(t, err)
+			assert.Equal(t, InstanceID(), holder)
+
+			require.NoError(t, lock.Refresh(ctx, time.Minute))
+			require.NoError(t, lock.Release(ctx))
+
+			other, err := locker.Acquire(ctx, "scheduler", time.Minute)
+			require.NoError(t, err, "a released lock can be taken")
+			assert.ErrorIs(t, lock.Refresh(ctx, time.Minute), ErrLockLost)
+			require.NoError(t, lock.Release(ctx), "releasing a lost lock is a no-op")
+			_, err = locker.Acquire(ctx, "scheduler", time.Minute)
+			assert.ErrorIs(t, err, ErrNotAcquired, "the stale release must not free the new holder's lock")
+			require.NoError(t, other.Release(ctx))
+		})
+	}
+}
+
+func TestLocker_ExpiredLockIsTakenOver(t *testing.T) {
+	for name, locker := range testLockers(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			stale, err := locker.Acquire(ctx, "mail", 20*time.Millisecond)
+			require.NoError(t, err)
+			time.Sleep(40 * time.Millisecond)
+
+			holder, _, err := locker.Holder(ctx, "mail")
+			require.NoError(t, err)
+			assert.Empty(t, holder)
+
+			_, err = locker.Acquire(ctx, "mail", time.Minute)
+			require.NoError(t, err)
+			assert.ErrorIs(t, stale.Refresh(ctx, time.Minute), ErrLockLost)
+		})
+	}
+}
+
+func TestWithLock(t *testing.T) {
+	ctx := context.Background()
+	locker := NewMemoryLocker()
+	ran := false
+	err := WithLock(ctx, locker, "report", time.Minute, func(ctx context.Context) error {
+		ran = true
+		_, err := locker.Acquire(ctx, "report", time.Minute)
+		assert.ErrorIs(t, err, ErrNotAcquired)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.True(t, ran)
+
+	holder, _, _ := locker.Holder(ctx, "report")
+	assert.Empty(t, holder, "the lock is released afterwards")
+
+	boom := errors.New("boom")
+	assert.ErrorIs(t, WithLock(ctx, locker, "report", time.Minute, func(context.Context) error { return boom }), boom)
+}
+
+func TestElector_FailsOverWhenLeaderStops(t *testing.T) {
+	locker := NewMemoryLocker()
+	lease := 60 * time.Millisecond
+	a := NewElector(locker, "scheduler", lease)
+	b := NewElector(locker, "scheduler", lease)
+
+	var leading atomic.Int32
+	lead := func(ctx context.Context) {
+		if leading.Add(1) > 1 {
+			t.Error("two leaders at once")
+		}
+		<-ctx.Done()
+		leading.Add(-1)
+	}
+
+	ctxA, stopA := context.WithCancel(context.Background())
+	doneA := make(chan struct{})
+	go func() { a.Run(ctxA, lead); close(doneA) }()
+	require.Eventually(t, a.IsLeader, time.Second, 5*time.Millisecond)
+
+	ctxB, stopB := context.WithCancel(context.Background())
+	defer stopB()
+	go b.Run(ctxB, lead)
+	time.Sleep(2 * lease)
+	assert.False(t, b.IsLeader(), "b follows while a leads")
+
+	stopA()
+	<-doneA
+	assert.False(t, a.IsLeader())
+	require.Eventually(t, b.IsLeader, time.Second, 5*time.Millisecond)
+}
@@ -0,0 +1,104 @@
+package coordination
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/goatkit/goatflow/internal/database"
+)
+
+// DBLocker keeps locks in the coordination_lock table. A lock is a row
+// with an expiry; holders take over expired rows with a conditional UPDATE,
+// so it works the same on PostgreSQL and MySQL without holding a
+// connection per lock.
+type DBLocker struct {
+	db *sql.DB
+}
+
+// NewDBLocker creates a locker using db.
+func NewDBLocker(db *sql.DB) *DBLocker {
+	return &DBLocker{db: db}
+}
+
+func (d *DBLocker) exec(ctx context.Context, query string, args ...interface{}) (int64, error) {
+	res, err := d.db.ExecContext(ctx, database.ConvertPlaceholders(query), args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// Acquire implements Locker.
+func (d *DBLocker) Acquire(ctx context.Context, name string, ttl time.Duration) (Lock, error) {
+	now := time.Now()
+	token := newToken()
+	n, err := d.exec(ctx, `
+		UPDATE coordination_lock SET holder = ?, token = ?, expires_at = ?, change_time = ?
+		WHERE name = ? AND expires_at <= ?`,
+		InstanceID(), token, now.Add(ttl), now, name, now)
+	if err != nil {
+		return nil, fmt.Errorf("take over lock %s: %w", name, err)
+	}
+	if n == 0 {
+		_, err = d.exec(ctx, `
+			INSERT INTO coordination_lock (name, holder, token, expires_at, change_time)
+			VALUES (?, ?, ?, ?, ?)`,
+			name, InstanceID(), token, now.Add(ttl), now)
+		if err != nil {
+			// Most likely a duplicate key: the lock exists and is held
+			if holder, _, herr := d.Holder(ctx, name); herr == nil && holder != "" {
+				return nil, ErrNotAcquired
+			}
+			return nil, fmt.Errorf("create lock %s: %w", name, err)
+		}
+	}
+	return &dbLock{d: d, name: name, token: token}, nil
+}
+
+// Holder implements Locker.
+func (d *DBLocker) Holder(ctx context.Context, name string) (string, time.Time, error) {
+	var holder string
+	var expires time.Time
+	err := d.db.QueryRowContext(ctx, database.ConvertPlaceholders(`
+		SELECT holder, expires_at FROM coordination_lock WHERE name = ? AND expires_at > ?`),
+		name, time.Now()).Scan(&holder, &expires)
+	if err == sql.ErrNoRows {
+		return "", time.Time{}, nil
+	}
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("load lock %s: %w", name, err)
+	}
+	return holder, expires, nil
+}
+
+type dbLock struct {
+	d     *DBLocker
+	name  string
+	token string
+}
+
+func (l *dbLock) Name() string { return l.name }
+
+func (l *dbLock) Refresh(ctx context.Context, ttl time.Duration) error {
+	now := time.Now()
+	n, err := l.d.exec(ctx, `
+		UPDATE coordination_lock SET expires_at = ?, change_time = ?
+		WHERE name = ? AND token = ? AND expires_at > ?`,
+		now.Add(ttl), now, l.name, l.token, now)
+	if err != nil {
+		return fmt.Errorf("refresh lock %s: %w", l.name, err)
+	}
+	if n == 0 {
+		return ErrLockLost
+	}
+	return nil
+}
+
+func (l *dbLock) Release(ctx context.Context) error {
+	if _, err := l.d.exec(ctx, `DELETE FROM coordination_lock WHERE name = ? AND token = ?`, l.name, l.token); err != nil {
+		return fmt.Errorf("release lock %s: %w", l.name, err)
+	}
+	return nil
+}
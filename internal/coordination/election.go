@@ -0,0 +1,110 @@
+package coordination
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultLease is how long a leader keeps its role without refreshing it,
+// and so roughly how long a dead leader's work pauses before another
+// replica takes over.
+const DefaultLease = 30 * time.Second
+
+// Elector runs leader election for one role among the replicas sharing a
+// Locker.
+type Elector struct {
+	locker Locker
+	name   string
+	lease  time.Duration
+	leader atomic.Bool
+
+	mu    sync.Mutex
+	since time.Time
+}
+
+// NewElector creates an elector for the role name. The leader holds the
+// lock "leader:<name>" for lease, DefaultLease when zero.
+func NewElector(locker Locker, name string, lease time.Duration) *Elector {
+	if lease <= 0 {
+		lease = DefaultLease
+	}
+	return &Elector{locker: locker, name: name, lease: lease}
+}
+
+// Name returns the role name.
+func (e *Elector) Name() string {
+	return e.name
+}
+
+// IsLeader reports whether this instance leads right now.
+func (e *Elector) IsLeader() bool {
+	return e.leader.Load()
+}
+
+// Leader returns the instance currently leading and since when this
+// instance leads, zero when it does not.
+func (e *Elector) Leader(ctx context.Context) (holder string, since time.Time, err error) {
+	holder, _, err = e.locker.Holder(ctx, e.lockName())
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.leader.Load() {
+		since = e.since
+	}
+	return holder, since, err
+}
+
+func (e *Elector) lockName() string {
+	return "leader:" + e.name
+}
+
+// Run competes for leadership until ctx is done. Each time this instance
+// becomes leader it calls lead with a context that is cancelled when
+// leadership is lost or ctx ends, and waits for lead to return before
+// competing again. lead must stop its work when its context is done.
+func (e *Elector) Run(ctx context.Context, lead func(ctx context.Context)) {
+	retry := e.lease / 3
+	for ctx.Err() == nil {
+		lock, err := e.locker.Acquire(ctx, e.lockName(), e.lease)
+		switch {
+		case err == nil:
+			e.runLeader(ctx, lock, lead)
+			continue
+		case ctx.Err() != nil:
+			return
+		case !errors.Is(err, ErrNotAcquired):
+			log.Printf("coordination: %s election failed: %v", e.name, err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(retry):
+		}
+	}
+}
+
+func (e *Elector) runLeader(ctx context.Context, lock Lock, lead func(ctx context.Context)) {
+	leadCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stop := keepAlive(leadCtx, lock, e.lease, func() {
+		log.Printf("coordination: lost %s leadership", e.name)
+		cancel()
+	})
+
+	e.mu.Lock()
+	e.since = time.Now()
+	e.mu.Unlock()
+	e.leader.Store(true)
+	log.Printf("coordination: %s leader is %s", e.name, InstanceID())
+
+	lead(leadCtx)
+
+	e.leader.Store(false)
+	stop()
+	if err := lock.Release(context.WithoutCancel(ctx)); err != nil {
+		log.Printf("coordination: release %s leadership: %v", e.name, err)
+	}
+}
@@ -0,0 +1,79 @@
+package coordination
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryLocker keeps locks in process memory. It only coordinates
+// goroutines of one process; use it for a single instance and in tests.
+type MemoryLocker struct {
+	mu    sync.Mutex
+	locks map[string]memoryLockEntry
+}
+
+type memoryLockEntry struct {
+	holder  string
+	token   string
+	expires time.Time
+}
+
+// NewMemoryLocker creates an empty in-memory locker.
+func NewMemoryLocker() *MemoryLocker {
+	return &MemoryLocker{locks: make(map[string]memoryLockEntry)}
+}
+
+// Acquire implements Locker.
+func (m *MemoryLocker) Acquire(_ context.Context, name string, ttl time.Duration) (Lock, error) {
+	now := time.Now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if e, ok := m.locks[name]; ok && now.Before(e.expires) {
+		return nil, ErrNotAcquired
+	}
+	token := newToken()
+	m.locks[name] = memoryLockEntry{holder: InstanceID(), token: token, expires: now.Add(ttl)}
+	return &memoryLock{m: m, name: name, token: token}, nil
+}
+
+// Holder implements Locker.
+func (m *MemoryLocker) Holder(_ context.Context, name string) (string, time.Time, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	e, ok := m.locks[name]
+	if !ok || time.Now().After(e.expires) {
+		return "", time.Time{}, nil
+	}
+	return e.holder, e.expires, nil
+}
+
+type memoryLock struct {
+	m     *MemoryLocker
+	name  string
+	token string
+}
+
+func (l *memoryLock) Name() string { return l.name }
+
+func (l *memoryLock) Refresh(_ context.Context, ttl time.Duration) error {
+	now := time.Now()
+	l.m.mu.Lock()
+	defer l.m.mu.Unlock()
+	e, ok := l.m.locks[l.name]
+	if !ok || e.token != l.token || now.After(e.expires) {
+		return ErrLockLost
+	}
+	e.expires = now.Add(ttl)
+	l.m.locks[l.name] = e
+	return nil
+}
+
+func (l *memoryLock) Release(context.Context) error {
+	l.m.mu.Lock()
+	defer l.m.mu.Unlock()
+	if e, ok := l.m.locks[l.name]; ok && e.token == l.token {
+		delete(l.m.locks, l.name)
+	}
+	return nil
+}
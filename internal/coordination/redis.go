@@ -0,0 +1,98 @@
+package coordination
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisLocker keeps locks in Valkey or Redis as keys with an expiry. The
+// value is the holder and a token, and refresh and release check the token
+// in a script, so a holder cannot touch a lock it lost.
+type RedisLocker struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+// NewRedisLocker creates a locker using client. Keys start with prefix,
+// "goatflow:lock:" when empty.
+func NewRedisLocker(client redis.UniversalClient, prefix string) *RedisLocker {
+	if prefix == "" {
+		prefix = "goatflow:lock:"
+	}
+	return &RedisLocker{client: client, prefix: prefix}
+}
+
+// Acquire implements Locker.
+func (r *RedisLocker) Acquire(ctx context.Context, name string, ttl time.Duration) (Lock, error) {
+	value := InstanceID() + " " + newToken()
+	ok, err := r.client.SetNX(ctx, r.prefix+name, value, ttl).Result()
+	if err != nil {
+		return nil, fmt.Errorf("acquire lock %s: %w", name, err)
+	}
+	if !ok {
+		return nil, ErrNotAcquired
+	}
+	return &redisLock{r: r, name: name, value: value}, nil
+}
+
+// Holder implements Locker.
+func (r *RedisLocker) Holder(ctx context.Context, name string) (string, time.Time, error) {
+	key := r.prefix + name
+	value, err := r.client.Get(ctx, key).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", time.Time{}, nil
+	}
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("load lock %s: %w", name, err)
+	}
+	ttl, err := r.client.PTTL(ctx, key).Result()
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("load lock %s: %w", name, err)
+	}
+	holder, _, _ := strings.Cut(value, " ")
+	return holder, time.Now().Add(ttl), nil
+}
+
+var (
+	redisRefreshScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+	redisReleaseScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+)
+
+type redisLock struct {
+	r     *RedisLocker
+	name  string
+	value string
+}
+
+func (l *redisLock) Name() string { return l.name }
+
+func (l *redisLock) Refresh(ctx context.Context, ttl time.Duration) error {
+	n, err := redisRefreshScript.Run(ctx, l.r.client, []string{l.r.prefix + l.name}, l.value, ttl.Milliseconds()).Int()
+	if err != nil {
+		return fmt.Errorf("refresh lock %s: %w", l.name, err)
+	}
+	if n == 0 {
+		return ErrLockLost
+	}
+	return nil
+}
+
+func (l *redisLock) Release(ctx context.Context) error {
+	if err := redisReleaseScript.Run(ctx, l.r.client, []string{l.r.prefix + l.name}, l.value).Err(); err != nil {
+		return fmt.Errorf("release lock %s: %w", l.name, err)
+	}
+	return nil
+}
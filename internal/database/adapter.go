@@ -200,6 +200,86 @@ func (m *MySQLAdapter) IntervalAdd(timestamp string, amount int, unit string) st
 	return fmt.Sprintf("DATE_ADD(%s, INTERVAL %d %s)", timestamp, amount, strings.ToUpper(unit))
 }
 
+// SQLiteAdapter implements DBAdapter for SQLite. Queries use ? placeholders
+// like MySQL, and RETURNING is emulated with LastInsertId so statements
+// behave the same on every SQLite version.
+type SQLiteAdapter struct{}
+
+func (a *SQLiteAdapter) InsertWithReturning(db *sql.DB, query string, args ...interface{}) (int64, error) {
+	query, expandedArgs := prepareQueryForSQLite(query, args)
+	result, err := db.Exec(removeReturningClause(query), expandedArgs...)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+func (a *SQLiteAdapter) InsertWithReturningTx(tx *sql.Tx, query string, args ...interface{}) (int64, error) {
+	query, expandedArgs := prepareQueryForSQLite(query, args)
+	result, err := tx.Exec(removeReturningClause(query), expandedArgs...)
+	if err != nil {
+		return 0, err
+	}
+	return result.LastInsertId()
+}
+
+func (a *SQLiteAdapter) Exec(db *sql.DB, query string, args ...interface{}) (sql.Result, error) {
+	query, expandedArgs := prepareQueryForSQLite(query, args)
+	return db.Exec(query, expandedArgs...)
+}
+
+func (a *SQLiteAdapter) ExecTx(tx *sql.Tx, query string, args ...interface{}) (sql.Result, error) {
+	query, expandedArgs := prepareQueryForSQLite(query, args)
+	return tx.Exec(query, expandedArgs...)
+}
+
+func (a *SQLiteAdapter) Query(db *sql.DB, query string, args ...interface{}) (*sql.Rows, error) {
+	query, expandedArgs := prepareQueryForSQLite(query, args)
+	return db.Query(query, expandedArgs...)
+}
+
+func (a *SQLiteAdapter) QueryTx(tx *sql.Tx, query string, args ...interface{}) (*sql.Rows, error) {
+	query, expandedArgs := prepareQueryForSQLite(query, args)
+	return tx.Query(query, expandedArgs...)
+}
+
+func (a *SQLiteAdapter) QueryRow(db *sql.DB, query string, args ...interface{}) *sql.Row {
+	query, expandedArgs := prepareQueryForSQLite(query, args)
+	return db.QueryRow(query, expandedArgs...)
+}
+
+func (a *SQLiteAdapter) QueryRowTx(tx *sql.Tx, query string, args ...interface{}) *sql.Row {
+	query, expandedArgs := prepareQueryForSQLite(query, args)
+	return tx.QueryRow(query, expandedArgs...)
+}
+
+func (a *SQLiteAdapter) CaseInsensitiveLike(column, pattern string) string {
+	// LIKE ignores case for ASCII; LOWER keeps it explicit like MySQL
+	return fmt.Sprintf("LOWER(%s) LIKE LOWER(%s)", column, pattern)
+}
+
+func (a *SQLiteAdapter) TypeCast(value, targetType string) string {
+	sqliteType := targetType
+	switch targetType {
+	case "text", "varchar":
+		sqliteType = "TEXT"
+	case "integer", "int", "bigint":
+		sqliteType = "INTEGER"
+	case "timestamp":
+		// Timestamps are stored as text
+		sqliteType = "TEXT"
+	}
+	return fmt.Sprintf("CAST(%s AS %s)", value, sqliteType)
+}
+
+func (a *SQLiteAdapter) IntervalAdd(timestamp string, amount int, unit string) string {
+	// SQLite: datetime(CURRENT_TIMESTAMP, '+1 minute')
+	if strings.EqualFold(timestamp, "NOW()") {
+		timestamp = "CURRENT_TIMESTAMP"
+	}
+	return fmt.Sprintf("datetime(%s, '%+d %s')", timestamp, amount, strings.ToLower(unit))
+}
+
 // Helper function to remove RETURNING clause from query.
 func removeReturningClause(query string) string {
 	// Remove RETURNING clause and everything after it
@@ -230,6 +310,14 @@ func prepareQueryForMySQL(query string, args []interface{}) (string, []interface
 	return removeReturningClause(convertedQuery), expandedArgs
 }
 
+// prepareQueryForSQLite expands args for PostgreSQL-style ($N) queries and
+// converts them to ?, then applies the SQLite dialect conversions.
+func prepareQueryForSQLite(query string, args []interface{}) (string, []interface{}) {
+	expandedArgs := remapArgsForRepeatedPlaceholders(query, args)
+	query = dollarPlaceholder.ReplaceAllString(query, "?")
+	return convertPlaceholders(query, "sqlite"), expandedArgs
+}
+
 // remapArgsForRepeatedPlaceholders expands args for queries with repeated $N placeholders
 // and handles out-of-order placeholders.
 // PostgreSQL allows $1 to appear multiple times, sharing the same arg, and any order.
@@ -305,6 +393,8 @@ func buildAdapterFromEnv() DBAdapter {
 	switch dbDriver {
 	case "mysql", "mariadb":
 		return &MySQLAdapter{}
+	case "sqlite", "sqlite3":
+		return &SQLiteAdapter{}
 	default:
 		return &PostgreSQLAdapter{}
 	}
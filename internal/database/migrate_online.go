@@ -0,0 +1,449 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+)
+
+// In online mode the Migrator runs expensive DDL so that the application
+// keeps serving while it runs, as during a blue/green upgrade:
+//
+//   - PostgreSQL builds and drops indexes CONCURRENTLY. These statements
+//     cannot run in a transaction, so a migration containing one runs
+//     statement by statement and is marked dirty until it completes, as
+//     on MySQL. An invalid index left by an interrupted build is dropped
+//     before the build is retried.
+//   - MySQL runs ALTER TABLE, CREATE INDEX and DROP INDEX with
+//     ALGORITHM=INPLACE, LOCK=NONE. A statement MySQL cannot run in place
+//     fails, unless offline changes are allowed.
+//   - Every statement waits at most LockTimeout for its locks instead of
+//     queueing behind long transactions and blocking all queries behind
+//     it. Statements that time out are retried after a pause.
+//
+// SQLite migrations run as usual.
+
+// Phases of a migration statement reported to OnlineOptions.Progress.
+const (
+	ProgressStatement = "statement" // A statement starts
+	ProgressRunning   = "running"   // A statement is still running
+	ProgressRetry     = "retry"     // A statement timed out waiting for a lock
+	ProgressOffline   = "offline"   // A statement runs without the online strategy
+	ProgressDone      = "done"      // A statement completed
+)
+
+// MigrationProgress reports the progress of an online migration.
+type MigrationProgress struct {
+	Version    uint
+	Name       string
+	Phase      string
+	Statement  int // 1-based
+	Statements int
+	SQL        string
+	// Done and Total are the work units the database reports for a running
+	// statement, such as blocks of an index build; 0 when unknown.
+	Done, Total int64
+	Elapsed     time.Duration
+	Err         error
+}
+
+// String formats p as a log line.
+func (p MigrationProgress) String() string {
+	prefix := fmt.Sprintf("%06d_%s [%d/%d]", p.Version, p.Name, p.Statement, p.Statements)
+	switch p.Phase {
+	case ProgressStatement:
+		return fmt.Sprintf("%s %s", prefix, shortenSQL(p.SQL))
+	case ProgressRunning:
+		if p.Total > 0 {
+			return fmt.Sprintf("%s running for %s, %.0f%% done", prefix, p.Elapsed.Round(time.Second), float64(p.Done)*100/float64(p.Total))
+		}
+		return fmt.Sprintf("%s running for %s", prefix, p.Elapsed.Round(time.Second))
+	case ProgressRetry:
+		return fmt.Sprintf("%s lock timeout, retrying: %v", prefix, p.Err)
+	case ProgressOffline:
+		return fmt.Sprintf("%s cannot run online, running offline: %v", prefix, p.Err)
+	case ProgressDone:
+		return fmt.Sprintf("%s done in %s", prefix, p.Elapsed.Round(time.Millisecond))
+	}
+	return prefix
+}
+
+// OnlineOptions configures online schema changes.
+type OnlineOptions struct {
+	// LockTimeout is how long a statement waits for a lock; default 5s.
+	LockTimeout time.Duration
+	// Retries is how often a statement that timed out is retried;
+	// default 5.
+	Retries int
+	// RetryDelay is the pause before the first retry, doubled for each
+	// further one; default 2s.
+	RetryDelay time.Duration
+	// AllowOffline runs MySQL statements that cannot run in place with
+	// the default algorithm, locking the table, instead of failing.
+	AllowOffline bool
+	// ProgressInterval is how often a running statement is reported;
+	// default 10s.
+	ProgressInterval time.Duration
+	// Progress receives the progress; may be nil.
+	Progress func(MigrationProgress)
+}
+
+// OnlineOptionsFromEnv returns the online options set by MIGRATIONS_ONLINE,
+// MIGRATIONS_LOCK_TIMEOUT, MIGRATIONS_LOCK_RETRIES and
+// MIGRATIONS_ALLOW_OFFLINE, and whether online mode is on.
+func OnlineOptionsFromEnv() (OnlineOptions, bool) {
+	var opts OnlineOptions
+	online, _ := strconv.ParseBool(os.Getenv("MIGRATIONS_ONLINE"))
+	if d, err := time.ParseDuration(os.Getenv("MIGRATIONS_LOCK_TIMEOUT")); err == nil {
+		opts.LockTimeout = d
+	}
+	if n, err := strconv.Atoi(os.Getenv("MIGRATIONS_LOCK_RETRIES")); err == nil {
+		opts.Retries = n
+	}
+	opts.AllowOffline, _ = strconv.ParseBool(os.Getenv("MIGRATIONS_ALLOW_OFFLINE"))
+	return opts, online
+}
+
+// SetOnline switches the Migrator to online schema changes.
+func (m *Migrator) SetOnline(opts OnlineOptions) {
+	if opts.LockTimeout <= 0 {
+		opts.LockTimeout = 5 * time.Second
+	}
+	if opts.Retries < 0 {
+		opts.Retries = 0
+	} else if opts.Retries == 0 {
+		opts.Retries = 5
+	}
+	if opts.RetryDelay <= 0 {
+		opts.RetryDelay = 2 * time.Second
+	}
+	if opts.ProgressInterval <= 0 {
+		opts.ProgressInterval = 10 * time.Second
+	}
+	m.online = &opts
+}
+
+var (
+	onlinePGCreateIndex    = regexp.MustCompile(`(?is)^CREATE\s+(UNIQUE\s+)?INDEX\s+(?:CONCURRENTLY\s+)?(?:IF\s+NOT\s+EXISTS\s+)?("?[\w.]+"?)\s+ON\s+(.+)$`)
+	onlinePGDropIndex      = regexp.MustCompile(`(?is)^DROP\s+INDEX\s+(?:CONCURRENTLY\s+)?(?:IF\s+EXISTS\s+)?("?[\w.]+"?)(?:\s+RESTRICT)?$`)
+	onlineMySQLAlter       = regexp.MustCompile(`(?is)^ALTER\s+TABLE\s`)
+	onlineMySQLCreateIndex = regexp.MustCompile(`(?is)^CREATE\s+(?:UNIQUE\s+)?INDEX\s`)
+	onlineMySQLDropIndex   = regexp.MustCompile(`(?is)^DROP\s+INDEX\s+\S+\s+ON\s`)
+	onlineMySQLAlgorithm   = regexp.MustCompile(`(?i)\b(?:ALGORITHM|LOCK)\s*=|\bPARTITION\b`)
+)
+
+// onlineStatement is a statement rewritten for online mode.
+type onlineStatement struct {
+	sql      string
+	original string
+	// concurrent statements cannot run in a transaction (PostgreSQL).
+	concurrent bool
+	// index is the index built concurrently (PostgreSQL).
+	index string
+	// inplace statements had the in-place algorithm added (MySQL).
+	inplace bool
+}
+
+// onlineRewrite returns stmt rewritten to its online form for dialect.
+// Statements without one are returned unchanged.
+func onlineRewrite(dialect, stmt string) onlineStatement {
+	st := onlineStatement{sql: stmt, original: stmt}
+	switch dialect {
+	case "pg":
+		if m := onlinePGCreateIndex.FindStringSubmatch(stmt); m != nil {
+			st.sql = fmt.Sprintf("CREATE %sINDEX CONCURRENTLY IF NOT EXISTS %s ON %s", strings.ToUpper(m[1]), m[2], m[3])
+			name := strings.Trim(m[2], `"`)
+			st.concurrent, st.index = true, name[strings.LastIndex(name, ".")+1:]
+		} else if m := onlinePGDropIndex.FindStringSubmatch(stmt); m != nil {
+			st.sql = "DROP INDEX CONCURRENTLY IF EXISTS " + m[1]
+			st.concurrent = true
+		}
+	case "mysql":
+		if onlineMySQLAlgorithm.MatchString(stmt) {
+			return st
+		}
+		switch {
+		case onlineMySQLAlter.MatchString(stmt):
+			st.sql, st.inplace = stmt+", ALGORITHM=INPLACE, LOCK=NONE", true
+		case onlineMySQLCreateIndex.MatchString(stmt), onlineMySQLDropIndex.MatchString(stmt):
+			st.sql, st.inplace = stmt+" ALGORITHM=INPLACE LOCK=NONE", true
+		}
+	}
+	return st
+}
+
+// isLockTimeout reports whether err is a statement giving up on a lock.
+func isLockTimeout(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		return pqErr.Code == "55P03" // lock_not_available
+	}
+	var myErr *mysql.MySQLError
+	if errors.As(err, &myErr) {
+		return myErr.Number == 1205 // ER_LOCK_WAIT_TIMEOUT, also for metadata locks
+	}
+	return false
+}
+
+// isOfflineOnly reports whether MySQL refused to run a statement in place.
+func isOfflineOnly(err error) bool {
+	var myErr *mysql.MySQLError
+	if errors.As(err, &myErr) {
+		// ER_ALTER_OPERATION_NOT_SUPPORTED and ..._REASON
+		return myErr.Number == 1845 || myErr.Number == 1846
+	}
+	return false
+}
+
+// applyOnline is apply in online mode.
+func (m *Migrator) applyOnline(ctx context.Context, conn *sql.Conn, mig Migration, script string, version uint,
+	finish func(sqlExecer) error) error {
+	stmts := splitSQLStatements(script)
+	rewritten := make([]onlineStatement, len(stmts))
+	concurrent := false
+	for i, stmt := range stmts {
+		rewritten[i] = onlineRewrite(m.dialect, stmt)
+		concurrent = concurrent || rewritten[i].concurrent
+	}
+
+	reset, err := m.setLockTimeout(ctx, conn)
+	if err != nil {
+		return err
+	}
+	defer reset()
+	pid := m.backendID(ctx, conn)
+
+	if m.dialect == "pg" && !concurrent {
+		// The whole transaction is retried when a statement times out
+		delay := m.online.RetryDelay
+		for attempt := 0; ; attempt++ {
+			err := m.applyOnlineTx(ctx, conn, mig, rewritten, pid, finish)
+			if err == nil || !isLockTimeout(err) || attempt >= m.online.Retries {
+				return err
+			}
+			m.report(MigrationProgress{Version: mig.Version, Name: mig.Name, Phase: ProgressRetry, Statements: len(rewritten), Err: err})
+			if err := sleepContext(ctx, delay); err != nil {
+				return err
+			}
+			delay *= 2
+		}
+	}
+
+	if err := m.setVersion(ctx, conn, mig.Version, true); err != nil {
+		return err
+	}
+	for i := range rewritten {
+		if err := m.execOnline(ctx, conn, mig, rewritten, i, pid); err != nil {
+			return err
+		}
+	}
+	return finish(conn)
+}
+
+func (m *Migrator) applyOnlineTx(ctx context.Context, conn *sql.Conn, mig Migration, stmts []onlineStatement, pid int64,
+	finish func(sqlExecer) error) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+	for i, st := range stmts {
+		if err := m.execWatched(ctx, tx, mig, st.sql, i, len(stmts), pid); err != nil {
+			return fmt.Errorf("%w\nStatement: %s", err, st.sql)
+		}
+	}
+	if err := finish(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// execOnline runs statement i outside a transaction, retrying it when it
+// times out waiting for a lock.
+func (m *Migrator) execOnline(ctx context.Context, conn *sql.Conn, mig Migration, stmts []onlineStatement, i int, pid int64) error {
+	st := stmts[i]
+	delay := m.online.RetryDelay
+	for attempt := 0; ; {
+		if st.index != "" {
+			if err := m.dropInvalidIndex(ctx, conn, st.index); err != nil {
+				return err
+			}
+		}
+		err := m.execWatched(ctx, conn, mig, st.sql, i, len(stmts), pid)
+		if err == nil {
+			return nil
+		}
+		if st.inplace && isOfflineOnly(err) {
+			if !m.online.AllowOffline {
+				return fmt.Errorf("statement cannot run online, run the migration in a maintenance window or allow offline changes: %w\nStatement: %s", err, st.original)
+			}
+			m.report(MigrationProgress{Version: mig.Version, Name: mig.Name, Phase: ProgressOffline, Statement: i + 1, Statements: len(stmts), SQL: st.original, Err: err})
+			st.sql, st.inplace = st.original, false
+			continue
+		}
+		if !isLockTimeout(err) || attempt >= m.online.Retries {
+			return fmt.Errorf("%w\nStatement: %s", err, st.sql)
+		}
+		attempt++
+		m.report(MigrationProgress{Version: mig.Version, Name: mig.Name, Phase: ProgressRetry, Statement: i + 1, Statements: len(stmts), SQL: st.sql, Err: err})
+		if err := sleepContext(ctx, delay); err != nil {
+			return err
+		}
+		delay *= 2
+	}
+}
+
+// execWatched runs one statement and reports its progress while it runs.
+func (m *Migrator) execWatched(ctx context.Context, ex sqlExecer, mig Migration, stmt string, i, n int, pid int64) error {
+	progress := MigrationProgress{Version: mig.Version, Name: mig.Name, Statement: i + 1, Statements: n, SQL: stmt}
+	progress.Phase = ProgressStatement
+	m.report(progress)
+
+	start := time.Now()
+	done := make(chan struct{})
+	watching := make(chan struct{})
+	go func() {
+		defer close(watching)
+		ticker := time.NewTicker(m.online.ProgressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+			p := progress
+			p.Phase, p.Elapsed = ProgressRunning, time.Since(start)
+			p.Done, p.Total = m.statementProgress(ctx, pid)
+			m.report(p)
+		}
+	}()
+	_, err := ex.ExecContext(ctx, stmt)
+	close(done)
+	<-watching
+	if err != nil {
+		return err
+	}
+	progress.Phase, progress.Elapsed = ProgressDone, time.Since(start)
+	m.report(progress)
+	return nil
+}
+
+// statementProgress asks the database, on another connection, how far the
+// statement of backend pid has got. It returns zeros when the database
+// does not tell.
+func (m *Migrator) statementProgress(ctx context.Context, pid int64) (int64, int64) {
+	if pid == 0 {
+		return 0, 0
+	}
+	qctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	var done, total sql.NullInt64
+	switch m.dialect {
+	case "pg":
+		var blocksDone, blocksTotal, tuplesDone, tuplesTotal sql.NullInt64
+		err := m.db.QueryRowContext(qctx, `SELECT blocks_done, blocks_total, tuples_done, tuples_total
+			FROM pg_stat_progress_create_index WHERE pid = $1`, pid).Scan(&blocksDone, &blocksTotal, &tuplesDone, &tuplesTotal)
+		if err != nil {
+			return 0, 0
+		}
+		done, total = blocksDone, blocksTotal
+		if total.Int64 == 0 {
+			done, total = tuplesDone, tuplesTotal
+		}
+	case "mysql":
+		err := m.db.QueryRowContext(qctx, `SELECT s.WORK_COMPLETED, s.WORK_ESTIMATED
+			FROM performance_schema.events_stages_current s
+			JOIN performance_schema.threads t ON t.THREAD_ID = s.THREAD_ID
+			WHERE t.PROCESSLIST_ID = ?`, pid).Scan(&done, &total)
+		if err != nil {
+			return 0, 0
+		}
+	}
+	return done.Int64, total.Int64
+}
+
+// backendID returns the server's ID of conn, used to look up its progress.
+func (m *Migrator) backendID(ctx context.Context, conn *sql.Conn) int64 {
+	query := "SELECT CONNECTION_ID()"
+	if m.dialect == "pg" {
+		query = "SELECT pg_backend_pid()"
+	}
+	var id int64
+	if err := conn.QueryRowContext(ctx, query).Scan(&id); err != nil {
+		return 0
+	}
+	return id
+}
+
+// setLockTimeout limits how long statements on conn wait for locks and
+// returns a function that restores the default.
+func (m *Migrator) setLockTimeout(ctx context.Context, conn *sql.Conn) (func(), error) {
+	switch m.dialect {
+	case "pg":
+		ms := max(m.online.LockTimeout.Milliseconds(), 1)
+		if _, err := conn.ExecContext(ctx, fmt.Sprintf("SET lock_timeout = %d", ms)); err != nil {
+			return nil, err
+		}
+		return func() { _, _ = conn.ExecContext(context.Background(), "RESET lock_timeout") }, nil
+	case "mysql":
+		secs := max(int64(m.online.LockTimeout.Round(time.Second)/time.Second), 1)
+		if _, err := conn.ExecContext(ctx, fmt.Sprintf("SET SESSION lock_wait_timeout = %d", secs)); err != nil {
+			return nil, err
+		}
+		return func() { _, _ = conn.ExecContext(context.Background(), "SET SESSION lock_wait_timeout = DEFAULT") }, nil
+	}
+	return func() {}, nil
+}
+
+// dropInvalidIndex drops the index left invalid by an interrupted
+// concurrent build, which CREATE INDEX IF NOT EXISTS would keep.
+func (m *Migrator) dropInvalidIndex(ctx context.Context, conn *sql.Conn, index string) error {
+	var invalid bool
+	err := conn.QueryRowContext(ctx, `SELECT NOT i.indisvalid FROM pg_index i
+		JOIN pg_class c ON c.oid = i.indexrelid
+		WHERE c.relname = $1 AND pg_table_is_visible(c.oid)`, index).Scan(&invalid)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && !invalid) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	_, err = conn.ExecContext(ctx, fmt.Sprintf(`DROP INDEX CONCURRENTLY IF EXISTS "%s"`, index))
+	return err
+}
+
+func (m *Migrator) report(p MigrationProgress) {
+	if m.online != nil && m.online.Progress != nil {
+		m.online.Progress(p)
+	}
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// shortenSQL returns stmt on one line, cut to 100 characters.
+func shortenSQL(stmt string) string {
+	stmt = strings.Join(strings.Fields(stmt), " ")
+	if len(stmt) > 100 {
+		stmt = stmt[:97] + "..."
+	}
+	return stmt
+}
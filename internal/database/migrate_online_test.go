@@ -0,0 +1,91 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOnlineRewrite(t *testing.T) {
+	tests := []struct {
+		dialect, stmt, want string
+		concurrent, inplace bool
+		index               string
+	}{
+		{"pg", "CREATE INDEX idx_ticket_queue ON ticket (queue_id)",
+			"CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_ticket_queue ON ticket (queue_id)", true, false, "idx_ticket_queue"},
+		{"pg", "create unique index if not exists public.uq_login on users (login)",
+			"CREATE UNIQUE INDEX CONCURRENTLY IF NOT EXISTS public.uq_login ON users (login)", true, false, "uq_login"},
+		{"pg", "DROP INDEX IF EXISTS idx_ticket_queue",
+			"DROP INDEX CONCURRENTLY IF EXISTS idx_ticket_queue", true, false, ""},
+		{"pg", "DROP INDEX a, b CASCADE", "DROP INDEX a, b CASCADE", false, false, ""},
+		{"pg", "ALTER TABLE ticket ADD COLUMN IF NOT EXISTS archived BOOLEAN",
+			"ALTER TABLE ticket ADD COLUMN IF NOT EXISTS archived BOOLEAN", false, false, ""},
+		{"mysql", "ALTER TABLE ticket ADD INDEX idx_queue (queue_id)",
+			"ALTER TABLE ticket ADD INDEX idx_queue (queue_id), ALGORITHM=INPLACE, LOCK=NONE", false, true, ""},
+		{"mysql", "CREATE INDEX idx_queue ON ticket (queue_id)",
+			"CREATE INDEX idx_queue ON ticket (queue_id) ALGORITHM=INPLACE LOCK=NONE", false, true, ""},
+		{"mysql", "DROP INDEX idx_queue ON ticket",
+			"DROP INDEX idx_queue ON ticket ALGORITHM=INPLACE LOCK=NONE", false, true, ""},
+		{"mysql", "ALTER TABLE ticket ADD COLUMN x INT, ALGORITHM=INSTANT",
+			"ALTER TABLE ticket ADD COLUMN x INT, ALGORITHM=INSTANT", false, false, ""},
+		{"mysql", "CREATE FULLTEXT INDEX ft_body ON article_data_mime (a_body)",
+			"CREATE FULLTEXT INDEX ft_body ON article_data_mime (a_body)", false, false, ""},
+		{"mysql", "INSERT INTO valid (name) VALUES ('x')", "INSERT INTO valid (name) VALUES ('x')", false, false, ""},
+	}
+	for _, tt := range tests {
+		st := onlineRewrite(tt.dialect, tt.stmt)
+		assert.Equal(t, tt.want, st.sql, tt.stmt)
+		assert.Equal(t, tt.stmt, st.original)
+		assert.Equal(t, tt.concurrent, st.concurrent, tt.stmt)
+		assert.Equal(t, tt.inplace, st.inplace, tt.stmt)
+		assert.Equal(t, tt.index, st.index, tt.stmt)
+	}
+}
+
+func TestOnlineErrors(t *testing.T) {
+	assert.True(t, isLockTimeout(fmt.Errorf("wrapped: %w", &pq.Error{Code: "55P03"})))
+	assert.True(t, isLockTimeout(&mysql.MySQLError{Number: 1205}))
+	assert.False(t, isLockTimeout(&mysql.MySQLError{Number: 1846}))
+	assert.False(t, isLockTimeout(sql.ErrNoRows))
+	assert.True(t, isOfflineOnly(&mysql.MySQLError{Number: 1846}))
+	assert.False(t, isOfflineOnly(&pq.Error{Code: "55P03"}))
+}
+
+func TestMigrationProgressString(t *testing.T) {
+	p := MigrationProgress{Version: 45, Name: "ticket_index", Statement: 2, Statements: 3,
+		SQL: "CREATE INDEX CONCURRENTLY IF NOT EXISTS idx\n  ON ticket (queue_id)"}
+	p.Phase = ProgressStatement
+	assert.Equal(t, "000045_ticket_index [2/3] CREATE INDEX CONCURRENTLY IF NOT EXISTS idx ON ticket (queue_id)", p.String())
+	p.Phase, p.Elapsed, p.Done, p.Total = ProgressRunning, 95*time.Second, 250, 1000
+	assert.Equal(t, "000045_ticket_index [2/3] running for 1m35s, 25% done", p.String())
+	p.Total = 0
+	assert.Equal(t, "000045_ticket_index [2/3] running for 1m35s", p.String())
+}
+
+func TestMigrator_OnlineSQLite(t *testing.T) {
+	db, err := sql.Open("sqlite3", "file::memory:")
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = db.Close() })
+
+	m, err := NewMigrator(db, "sqlite", testMigrationsFS())
+	require.NoError(t, err)
+	var progress []MigrationProgress
+	m.SetOnline(OnlineOptions{Retries: -1, Progress: func(p MigrationProgress) { progress = append(progress, p) }})
+	assert.Equal(t, 5*time.Second, m.online.LockTimeout)
+	assert.Zero(t, m.online.Retries)
+
+	// SQLite has no online strategies and migrates as usual
+	applied, err := m.Up(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, len(m.Migrations()), applied)
+	assert.Empty(t, progress)
+}
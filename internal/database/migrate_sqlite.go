@@ -0,0 +1,137 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+
+	"github.com/goatkit/goatflow/schema"
+)
+
+// SQLite has no migrations of its own. The Migrator translates the
+// PostgreSQL migrations statement by statement.
+
+var (
+	sqliteSerialPK    = regexp.MustCompile(`(?i)\b(?:BIG|SMALL)?SERIAL\s+PRIMARY\s+KEY\b`)
+	sqliteSerial      = regexp.MustCompile(`(?i)\b(?:BIG|SMALL)?SERIAL\b`)
+	sqliteBlob        = regexp.MustCompile(`(?i)\bBYTEA\b`)
+	sqliteJSON        = regexp.MustCompile(`(?i)\bJSONB?\b`)
+	sqliteTimestampTZ = regexp.MustCompile(`(?i)\bTIMESTAMPTZ\b|\bTIMESTAMP\s+WITH(?:OUT)?\s+TIME\s+ZONE\b`)
+	sqliteNow         = regexp.MustCompile(`(?i)\bNOW\(\)`)
+	sqliteCast        = regexp.MustCompile(`::\w+`)
+	sqliteAddColumn   = regexp.MustCompile(`(?is)^ALTER\s+TABLE\s+(\w+)\s+ADD\s+COLUMN\s+IF\s+NOT\s+EXISTS\s+(\w+)`)
+	sqliteSkipped     = regexp.MustCompile(`(?i)^(?:DO\s|CREATE\s+EXTENSION\s|BEGIN$|COMMIT$|SELECT\s+setval\()`)
+	sqliteEnumType    = regexp.MustCompile(`(?is)^CREATE\s+TYPE\s+(\w+)\s+AS\s+ENUM\b`)
+)
+
+// sqliteDialect translates PostgreSQL scripts. Enum types become TEXT
+// columns, so it remembers the enums created by earlier statements.
+type sqliteDialect struct {
+	enums map[string]bool
+}
+
+// exec runs every statement of a PostgreSQL script.
+func (d *sqliteDialect) exec(ctx context.Context, ex sqlExecer, script string) error {
+	for _, stmt := range splitSQLStatements(script) {
+		stmt = d.translate(stmt)
+		if stmt == "" {
+			continue
+		}
+		// SQLite cannot add a column conditionally
+		if m := sqliteAddColumn.FindStringSubmatch(stmt); m != nil {
+			exists, err := sqliteColumnExists(ctx, ex, m[1], m[2])
+			if err != nil {
+				return err
+			}
+			if exists {
+				continue
+			}
+			stmt = regexp.MustCompile(`(?i)\s+IF\s+NOT\s+EXISTS`).ReplaceAllString(stmt, "")
+		}
+		if _, err := ex.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("%w\nStatement: %s", err, stmt)
+		}
+	}
+	return nil
+}
+
+func sqliteColumnExists(ctx context.Context, ex sqlExecer, table, column string) (bool, error) {
+	var n int
+	err := ex.QueryRowContext(ctx, "SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?", table, column).Scan(&n)
+	return n > 0, err
+}
+
+// translate rewrites a PostgreSQL statement for SQLite. It returns "" for
+// statements SQLite has no equivalent for, such as DO blocks; those only seed
+// optional data.
+func (d *sqliteDialect) translate(stmt string) string {
+	if sqliteSkipped.MatchString(stmt) {
+		return ""
+	}
+	if m := sqliteEnumType.FindStringSubmatch(stmt); m != nil {
+		d.enums[strings.ToLower(m[1])] = true
+		return ""
+	}
+	return mapOutsideLiterals(stmt, func(code string) string {
+		for enum := range d.enums {
+			code = regexp.MustCompile(`(?i)\b`+enum+`\b`).ReplaceAllString(code, "TEXT")
+		}
+		code = sqliteSerialPK.ReplaceAllString(code, "INTEGER PRIMARY KEY AUTOINCREMENT")
+		code = sqliteSerial.ReplaceAllString(code, "INTEGER")
+		code = sqliteBlob.ReplaceAllString(code, "BLOB")
+		code = sqliteJSON.ReplaceAllString(code, "TEXT")
+		code = sqliteTimestampTZ.ReplaceAllString(code, "TIMESTAMP")
+		code = sqliteNow.ReplaceAllString(code, "CURRENT_TIMESTAMP")
+		return sqliteCast.ReplaceAllString(code, "")
+	})
+}
+
+// mapOutsideLiterals applies fn to the parts of stmt outside string literals.
+func mapOutsideLiterals(stmt string, fn func(string) string) string {
+	var b strings.Builder
+	start := 0
+	for i := 0; i < len(stmt); i++ {
+		if stmt[i] != '\'' {
+			continue
+		}
+		b.WriteString(fn(stmt[start:i]))
+		end := i + 1
+		for end < len(stmt) {
+			if stmt[end] == '\'' {
+				if end+1 < len(stmt) && stmt[end+1] == '\'' {
+					end += 2
+					continue
+				}
+				break
+			}
+			end++
+		}
+		end = min(end+1, len(stmt))
+		b.WriteString(stmt[i:end])
+		start, i = end, end-1
+	}
+	b.WriteString(fn(stmt[start:]))
+	return b.String()
+}
+
+// seedSQLiteLookups loads the required lookup rows (states, priorities,
+// ...) into a freshly migrated database, which the Makefile does for the
+// other databases.
+func seedSQLiteLookups(ctx context.Context, conn *sql.Conn) {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		log.Printf("migrations: could not seed lookups: %v", err)
+		return
+	}
+	defer func() { _ = tx.Rollback() }()
+	if err := (&sqliteDialect{enums: map[string]bool{}}).exec(ctx, tx, schema.RequiredLookups); err != nil {
+		log.Printf("migrations: could not seed lookups: %v", err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		log.Printf("migrations: could not seed lookups: %v", err)
+	}
+}
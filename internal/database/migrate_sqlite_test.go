@@ -0,0 +1,86 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/migrations"
+)
+
+func TestMigrator_SQLite(t *testing.T) {
+	db, err := sql.Open("sqlite3", "file::memory:?_foreign_keys=on")
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = db.Close() })
+
+	m, err := NewMigrator(db, "sqlite", migrations.FS)
+	require.NoError(t, err)
+	applied, err := m.Up(context.Background())
+	require.NoError(t, err)
+	assert.Greater(t, applied, 20)
+
+	version, dirty, err := GetMigrationVersion(db)
+	require.NoError(t, err)
+	assert.False(t, dirty)
+	assert.GreaterOrEqual(t, version, uint(24))
+
+	// Lookups are seeded on a fresh database
+	var states int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM ticket_state").Scan(&states))
+	assert.Positive(t, states)
+
+	// Groups created by migrations must not take the ids of seeded groups
+	var group string
+	require.NoError(t, db.QueryRow("SELECT name FROM groups WHERE id = 1").Scan(&group))
+	assert.Equal(t, "users", group)
+	var kbEditorID int
+	require.NoError(t, db.QueryRow("SELECT id FROM groups WHERE name = 'kb_editor'").Scan(&kbEditorID))
+	assert.Greater(t, kbEditorID, 5)
+
+	applied, err = m.Up(context.Background())
+	require.NoError(t, err)
+	assert.Zero(t, applied, "migrations must not be applied twice")
+
+	t.Setenv("TEST_DB_DRIVER", "sqlite")
+	adapter := &SQLiteAdapter{}
+	id, err := adapter.InsertWithReturning(db, `INSERT INTO valid (name, create_time, create_by, change_time, change_by)
+		VALUES ($1, NOW(), $2, NOW(), $2) RETURNING id`, "archived", 1)
+	require.NoError(t, err)
+	assert.Positive(t, id)
+
+	var name string
+	require.NoError(t, db.QueryRow(ConvertPlaceholders("SELECT name FROM valid WHERE id = ? AND name ILIKE ?"), id, "ARCHIVED").Scan(&name))
+	assert.Equal(t, "archived", name)
+
+	var later bool
+	require.NoError(t, db.QueryRow("SELECT "+adapter.IntervalAdd("NOW()", 1, "HOUR")+" > CURRENT_TIMESTAMP").Scan(&later))
+	assert.True(t, later)
+}
+
+func TestSQLiteDialect_Translate(t *testing.T) {
+	d := &sqliteDialect{enums: map[string]bool{}}
+	assert.Equal(t, "CREATE TABLE x (id INTEGER PRIMARY KEY AUTOINCREMENT, data BLOB, meta TEXT, note TEXT DEFAULT 'json::text NOW()')",
+		d.translate("CREATE TABLE x (id BIGSERIAL PRIMARY KEY, data BYTEA, meta JSONB, note TEXT DEFAULT 'json::text NOW()')"))
+	assert.Equal(t, "SELECT CURRENT_TIMESTAMP, 'a'", d.translate("SELECT NOW()::timestamp, 'a'::text"))
+	assert.Empty(t, d.translate("DO $$ BEGIN RAISE NOTICE 'x'; END $$"))
+
+	assert.Empty(t, d.translate("CREATE TYPE kind_enum AS ENUM ('a', 'b')"))
+	assert.Equal(t, "CREATE TABLE y (kind TEXT NOT NULL DEFAULT 'a')", d.translate("CREATE TABLE y (kind kind_enum NOT NULL DEFAULT 'a')"))
+}
+
+func TestSplitSQLStatements(t *testing.T) {
+	stmts := splitSQLStatements(`-- leading; comment
+CREATE TABLE a (v TEXT DEFAULT ';');
+DO $$ BEGIN PERFORM 1; END $$;
+/* block; comment */ INSERT INTO a VALUES ('it''s; fine');`)
+	assert.Equal(t, []string{
+		"CREATE TABLE a (v TEXT DEFAULT ';')",
+		"DO $$ BEGIN PERFORM 1; END $$",
+		"INSERT INTO a VALUES ('it''s; fine')",
+	}, stmts)
+}
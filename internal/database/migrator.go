@@ -0,0 +1,548 @@
+package database
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The migrator keeps the current version in schema_migrations exactly like
+// the golang-migrate CLI did, so databases migrated by either tool carry on
+// where they are. Checksums of the applied up migrations go to
+// schema_migration_history.
+
+var (
+	// ErrDirtyDatabase is returned when an earlier migration failed halfway.
+	// Fix the schema by hand, then record the version with Force.
+	ErrDirtyDatabase = errors.New("database is in dirty state")
+	// ErrMigrationsModified is returned when an applied migration no longer
+	// matches the checksum recorded when it ran. Repair records the new ones.
+	ErrMigrationsModified = errors.New("applied migrations were modified")
+)
+
+// Migration is one versioned schema change.
+type Migration struct {
+	Version  uint
+	Name     string
+	Up       string
+	Down     string
+	Checksum string // SHA-256 of Up
+}
+
+// MigrationInfo is the state of one migration in a database.
+type MigrationInfo struct {
+	Version   uint       `json:"version"`
+	Name      string     `json:"name"`
+	Applied   bool       `json:"applied"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+	Checksum  string     `json:"checksum"`
+	Modified  bool       `json:"modified"`
+	Dirty     bool       `json:"dirty,omitempty"`
+}
+
+// MigrationStatus summarizes the migrations of a database.
+type MigrationStatus struct {
+	Version    uint            `json:"version"`
+	Latest     uint            `json:"latest"`
+	Dirty      bool            `json:"dirty"`
+	Pending    int             `json:"pending"`
+	Modified   int             `json:"modified"`
+	Migrations []MigrationInfo `json:"migrations"`
+}
+
+// LoadMigrations reads NNNNNN_name.up.sql and NNNNNN_name.down.sql files
+// from dir, ordered by version. Every version needs an up migration.
+func LoadMigrations(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read migrations: %w", err)
+	}
+
+	byVersion := map[uint]*Migration{}
+	for _, entry := range entries {
+		base, up := strings.CutSuffix(entry.Name(), ".up.sql")
+		if !up {
+			var down bool
+			if base, down = strings.CutSuffix(entry.Name(), ".down.sql"); !down {
+				continue
+			}
+		}
+		prefix, name, _ := strings.Cut(base, "_")
+		version, err := strconv.ParseUint(prefix, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: version is not a number", entry.Name())
+		}
+		content, err := fs.ReadFile(fsys, dir+"/"+entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		m := byVersion[uint(version)]
+		if m == nil {
+			m = &Migration{Version: uint(version), Name: name}
+			byVersion[m.Version] = m
+		}
+		if up {
+			sum := sha256.Sum256(content)
+			m.Up, m.Checksum = string(content), hex.EncodeToString(sum[:])
+		} else {
+			m.Down = string(content)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %06d_%s has no up migration", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+// Migrator applies the migrations for one database driver.
+type Migrator struct {
+	db         *sql.DB
+	dialect    string // "pg", "mysql" or "sqlite"
+	migrations []Migration
+	sqlite     *sqliteDialect
+	online     *OnlineOptions // nil unless SetOnline was called
+}
+
+// NewMigrator loads the migrations for driver from fsys, which holds one
+// directory per database like the migrations directory of the repository.
+func NewMigrator(db *sql.DB, driver string, fsys fs.FS) (*Migrator, error) {
+	if db == nil {
+		return nil, fmt.Errorf("database connection is nil")
+	}
+	dialect, dir := "pg", "postgres"
+	switch strings.ToLower(driver) {
+	case "postgres", "postgresql":
+	case "mysql", "mariadb":
+		dialect, dir = "mysql", "mysql"
+	case "sqlite", "sqlite3":
+		// SQLite runs the PostgreSQL migrations translated
+		dialect = "sqlite"
+	default:
+		return nil, fmt.Errorf("unsupported database driver: %s", driver)
+	}
+	migrations, err := LoadMigrations(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, dialect: dialect, migrations: migrations, sqlite: &sqliteDialect{enums: map[string]bool{}}}, nil
+}
+
+// Migrations returns the known migrations, oldest first.
+func (m *Migrator) Migrations() []Migration {
+	return m.migrations
+}
+
+// Up applies every pending migration and returns how many were applied.
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	conn, release, err := m.conn(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer release()
+
+	version, dirty, err := m.version(ctx, conn)
+	if err != nil {
+		return 0, err
+	}
+	if dirty {
+		return 0, fmt.Errorf("%w at version %d", ErrDirtyDatabase, version)
+	}
+	history, err := m.history(ctx, conn)
+	if err != nil {
+		return 0, err
+	}
+
+	// Record checksums for migrations applied before the history existed
+	var modified []string
+	for _, mig := range m.migrations {
+		if mig.Version > version {
+			break
+		}
+		h, ok := history[mig.Version]
+		if !ok {
+			if err := m.record(ctx, conn, mig); err != nil {
+				return 0, err
+			}
+			continue
+		}
+		if h.checksum != mig.Checksum {
+			modified = append(modified, fmt.Sprintf("%06d_%s", mig.Version, mig.Name))
+		}
+	}
+	if len(modified) > 0 {
+		return 0, fmt.Errorf("%w: %s", ErrMigrationsModified, strings.Join(modified, ", "))
+	}
+
+	applied := 0
+	for _, mig := range m.migrations {
+		if mig.Version <= version {
+			continue
+		}
+		if err := m.apply(ctx, conn, mig, mig.Up, mig.Version); err != nil {
+			return applied, fmt.Errorf("migration %06d_%s failed: %w", mig.Version, mig.Name, err)
+		}
+		applied++
+	}
+
+	if m.dialect == "sqlite" && version == 0 && applied > 0 {
+		seedSQLiteLookups(ctx, conn)
+	}
+	return applied, nil
+}
+
+// Down reverts the last steps applied migrations and returns how many were
+// reverted.
+func (m *Migrator) Down(ctx context.Context, steps int) (int, error) {
+	conn, release, err := m.conn(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer release()
+
+	version, dirty, err := m.version(ctx, conn)
+	if err != nil {
+		return 0, err
+	}
+	if dirty {
+		return 0, fmt.Errorf("%w at version %d", ErrDirtyDatabase, version)
+	}
+
+	i := sort.Search(len(m.migrations), func(i int) bool { return m.migrations[i].Version >= version })
+	if version > 0 && (i == len(m.migrations) || m.migrations[i].Version != version) {
+		return 0, fmt.Errorf("database version %d has no migration", version)
+	}
+	reverted := 0
+	for ; version > 0 && reverted < steps; i-- {
+		mig := m.migrations[i]
+		if mig.Down == "" {
+			return reverted, fmt.Errorf("migration %06d_%s cannot be reverted", mig.Version, mig.Name)
+		}
+		version = 0
+		if i > 0 {
+			version = m.migrations[i-1].Version
+		}
+		if err := m.apply(ctx, conn, mig, mig.Down, version); err != nil {
+			return reverted, fmt.Errorf("reverting %06d_%s failed: %w", mig.Version, mig.Name, err)
+		}
+		reverted++
+	}
+	return reverted, nil
+}
+
+// Force sets the version without running migrations and clears the dirty
+// flag, after a failed migration was completed or undone by hand.
+func (m *Migrator) Force(ctx context.Context, version uint) error {
+	conn, release, err := m.conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer release()
+	return m.setVersion(ctx, conn, version, false)
+}
+
+// Repair records the current checksums of the applied migrations, accepting
+// edits made after they ran. It returns how many checksums changed.
+func (m *Migrator) Repair(ctx context.Context) (int, error) {
+	conn, release, err := m.conn(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer release()
+
+	version, _, err := m.version(ctx, conn)
+	if err != nil {
+		return 0, err
+	}
+	history, err := m.history(ctx, conn)
+	if err != nil {
+		return 0, err
+	}
+	repaired := 0
+	for _, mig := range m.migrations {
+		if h, ok := history[mig.Version]; mig.Version > version || !ok || h.checksum == mig.Checksum {
+			continue
+		}
+		if _, err := conn.ExecContext(ctx, m.q("UPDATE schema_migration_history SET checksum = ?, name = ? WHERE version = ?"),
+			mig.Checksum, mig.Name, mig.Version); err != nil {
+			return repaired, err
+		}
+		repaired++
+	}
+	return repaired, nil
+}
+
+// Status reports the version of the database and the state of every
+// migration.
+func (m *Migrator) Status(ctx context.Context) (*MigrationStatus, error) {
+	conn, release, err := m.conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	version, dirty, err := m.version(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	history, err := m.history(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &MigrationStatus{Version: version, Dirty: dirty, Migrations: make([]MigrationInfo, 0, len(m.migrations))}
+	for _, mig := range m.migrations {
+		info := MigrationInfo{
+			Version:  mig.Version,
+			Name:     mig.Name,
+			Applied:  mig.Version <= version,
+			Checksum: mig.Checksum,
+			Dirty:    dirty && mig.Version == version,
+		}
+		if h, ok := history[mig.Version]; ok && info.Applied {
+			appliedAt := h.appliedAt
+			info.AppliedAt = &appliedAt
+			info.Modified = h.checksum != mig.Checksum
+		}
+		if info.Modified {
+			status.Modified++
+		}
+		if !info.Applied {
+			status.Pending++
+		}
+		status.Latest = mig.Version
+		status.Migrations = append(status.Migrations, info)
+	}
+	return status, nil
+}
+
+// conn pins one connection for the whole run and makes sure the version
+// tables exist.
+func (m *Migrator) conn(ctx context.Context) (*sql.Conn, func(), error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	release := func() { _ = conn.Close() }
+	if m.dialect == "sqlite" {
+		// Migrations seed rows that reference lookups and the admin user,
+		// which are only created afterwards, so foreign keys are not
+		// enforced here.
+		if _, err := conn.ExecContext(ctx, "PRAGMA foreign_keys = OFF"); err != nil {
+			release()
+			return nil, nil, err
+		}
+		release = func() {
+			_, _ = conn.ExecContext(context.Background(), "PRAGMA foreign_keys = ON")
+			_ = conn.Close()
+		}
+	}
+
+	for _, stmt := range []string{
+		`CREATE TABLE IF NOT EXISTS schema_migrations (
+			version BIGINT NOT NULL PRIMARY KEY,
+			dirty BOOLEAN NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS schema_migration_history (
+			version BIGINT NOT NULL PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			checksum VARCHAR(64) NOT NULL,
+			applied_at TIMESTAMP NOT NULL
+		)`,
+	} {
+		if _, err := conn.ExecContext(ctx, stmt); err != nil {
+			release()
+			return nil, nil, fmt.Errorf("failed to create migration tables: %w", err)
+		}
+	}
+	return conn, release, nil
+}
+
+func (m *Migrator) version(ctx context.Context, conn *sql.Conn) (uint, bool, error) {
+	var version int64
+	var dirty bool
+	err := conn.QueryRowContext(ctx, "SELECT version, dirty FROM schema_migrations LIMIT 1").Scan(&version, &dirty)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return uint(version), dirty, nil
+}
+
+type migrationRecord struct {
+	checksum  string
+	appliedAt time.Time
+}
+
+func (m *Migrator) history(ctx context.Context, conn *sql.Conn) (map[uint]migrationRecord, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT version, checksum, applied_at FROM schema_migration_history")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	history := map[uint]migrationRecord{}
+	for rows.Next() {
+		var version int64
+		var r migrationRecord
+		if err := rows.Scan(&version, &r.checksum, &r.appliedAt); err != nil {
+			return nil, err
+		}
+		history[uint(version)] = r
+	}
+	return history, rows.Err()
+}
+
+// sqlExecer is a connection or a transaction.
+type sqlExecer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func (m *Migrator) record(ctx context.Context, ex sqlExecer, mig Migration) error {
+	_, err := ex.ExecContext(ctx, m.q("INSERT INTO schema_migration_history (version, name, checksum, applied_at) VALUES (?, ?, ?, ?)"),
+		mig.Version, mig.Name, mig.Checksum, time.Now().UTC())
+	return err
+}
+
+func (m *Migrator) setVersion(ctx context.Context, ex sqlExecer, version uint, dirty bool) error {
+	if _, err := ex.ExecContext(ctx, "DELETE FROM schema_migrations"); err != nil {
+		return err
+	}
+	if version == 0 && !dirty {
+		return nil
+	}
+	_, err := ex.ExecContext(ctx, m.q("INSERT INTO schema_migrations (version, dirty) VALUES (?, ?)"), version, dirty)
+	return err
+}
+
+// apply runs script and moves the database to version. PostgreSQL and
+// SQLite run it in one transaction. MySQL commits DDL implicitly, so the
+// version is marked dirty until the script has completed. In online mode
+// applyOnline runs it instead.
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, mig Migration, script string, version uint) error {
+	up := version == mig.Version
+	finish := func(ex sqlExecer) error {
+		if err := m.setVersion(ctx, ex, version, false); err != nil {
+			return err
+		}
+		if _, err := ex.ExecContext(ctx, m.q("DELETE FROM schema_migration_history WHERE version = ?"), mig.Version); err != nil {
+			return err
+		}
+		if up {
+			return m.record(ctx, ex, mig)
+		}
+		return nil
+	}
+
+	if m.online != nil && m.dialect != "sqlite" {
+		return m.applyOnline(ctx, conn, mig, script, version, finish)
+	}
+	if m.dialect == "mysql" {
+		if err := m.setVersion(ctx, conn, mig.Version, true); err != nil {
+			return err
+		}
+		if err := m.exec(ctx, conn, script); err != nil {
+			return err
+		}
+		return finish(conn)
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+	if err := m.exec(ctx, tx, script); err != nil {
+		return err
+	}
+	if err := finish(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// exec runs every statement of script.
+func (m *Migrator) exec(ctx context.Context, ex sqlExecer, script string) error {
+	if m.dialect == "sqlite" {
+		return m.sqlite.exec(ctx, ex, script)
+	}
+	for _, stmt := range splitSQLStatements(script) {
+		if _, err := ex.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("%w\nStatement: %s", err, stmt)
+		}
+	}
+	return nil
+}
+
+// q converts ? placeholders for the migrator's database.
+func (m *Migrator) q(query string) string {
+	return convertPlaceholders(query, m.dialect)
+}
+
+// splitSQLStatements splits a script into statements on semicolons outside
+// literals, dollar-quoted bodies and comments. Comments are dropped.
+func splitSQLStatements(script string) []string {
+	var stmts []string
+	var cur strings.Builder
+	flush := func() {
+		if s := strings.TrimSpace(cur.String()); s != "" {
+			stmts = append(stmts, s)
+		}
+		cur.Reset()
+	}
+	for i := 0; i < len(script); i++ {
+		c := script[i]
+		switch {
+		case c == '-' && strings.HasPrefix(script[i:], "--"):
+			end := strings.IndexByte(script[i:], '\n')
+			if end < 0 {
+				i = len(script)
+			} else {
+				i += end - 1
+			}
+		case c == '/' && strings.HasPrefix(script[i:], "/*"):
+			end := strings.Index(script[i+2:], "*/")
+			if end < 0 {
+				i = len(script)
+			} else {
+				i += end + 3
+			}
+		case c == '\'' || c == '"' || c == '`':
+			end := i + 1
+			for end < len(script) && script[end] != c {
+				end++
+			}
+			cur.WriteString(script[i:min(end+1, len(script))])
+			i = end
+		case c == '$' && strings.HasPrefix(script[i:], "$$"):
+			end := strings.Index(script[i+2:], "$$")
+			if end < 0 {
+				end = len(script) - i - 2
+			}
+			cur.WriteString(script[i:min(i+end+4, len(script))])
+			i += end + 3
+		case c == ';':
+			flush()
+		default:
+			cur.WriteByte(c)
+		}
+	}
+	flush()
+	return stmts
+}
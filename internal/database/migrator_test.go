@@ -0,0 +1,164 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"testing/fstest"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testMigrationsFS() fstest.MapFS {
+	return fstest.MapFS{
+		"postgres/000001_notes.up.sql":   {Data: []byte("CREATE TABLE notes (id SERIAL PRIMARY KEY, body TEXT);")},
+		"postgres/000001_notes.down.sql": {Data: []byte("DROP TABLE notes;")},
+		"postgres/000002_tags.up.sql":    {Data: []byte("CREATE TABLE tags (id SERIAL PRIMARY KEY, name TEXT);")},
+		"postgres/000002_tags.down.sql":  {Data: []byte("DROP TABLE tags;")},
+		"postgres/000003_no_down.up.sql": {Data: []byte("ALTER TABLE notes ADD COLUMN IF NOT EXISTS title TEXT;")},
+		"postgres/README.md":             {Data: []byte("not a migration")},
+		"mysql/000001_notes.up.sql":      {Data: []byte("CREATE TABLE notes (id INT AUTO_INCREMENT PRIMARY KEY);")},
+	}
+}
+
+func TestLoadMigrations(t *testing.T) {
+	migs, err := LoadMigrations(testMigrationsFS(), "postgres")
+	require.NoError(t, err)
+	require.Len(t, migs, 3)
+	assert.Equal(t, uint(1), migs[0].Version)
+	assert.Equal(t, "notes", migs[0].Name)
+	assert.Equal(t, "DROP TABLE notes;", migs[0].Down)
+	assert.Len(t, migs[0].Checksum, 64)
+	assert.Empty(t, migs[2].Down)
+
+	_, err = LoadMigrations(fstest.MapFS{"postgres/000001_x.down.sql": {Data: []byte("SELECT 1;")}}, "postgres")
+	assert.Error(t, err, "a down migration without an up migration is rejected")
+}
+
+func TestMigrator_UpDownStatus(t *testing.T) {
+	db, err := sql.Open("sqlite3", "file::memory:")
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = db.Close() })
+	ctx := context.Background()
+
+	fsys := testMigrationsFS()
+	m, err := NewMigrator(db, "sqlite", fsys)
+	require.NoError(t, err)
+
+	applied, err := m.Up(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 3, applied)
+
+	status, err := m.Status(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, uint(3), status.Version)
+	assert.Equal(t, uint(3), status.Latest)
+	assert.Zero(t, status.Pending)
+	assert.Zero(t, status.Modified)
+	require.Len(t, status.Migrations, 3)
+	assert.NotNil(t, status.Migrations[0].AppliedAt)
+
+	// The last migration has no down migration
+	_, err = m.Down(ctx, 1)
+	assert.Error(t, err)
+
+	require.NoError(t, m.Force(ctx, 2))
+	reverted, err := m.Down(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 1, reverted)
+	status, err = m.Status(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, uint(1), status.Version)
+	assert.Equal(t, 2, status.Pending)
+
+	applied, err = m.Up(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, applied)
+}
+
+func TestMigrator_DetectsModifiedMigrations(t *testing.T) {
+	db, err := sql.Open("sqlite3", "file::memory:")
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = db.Close() })
+	ctx := context.Background()
+
+	fsys := testMigrationsFS()
+	m, err := NewMigrator(db, "sqlite", fsys)
+	require.NoError(t, err)
+	_, err = m.Up(ctx)
+	require.NoError(t, err)
+
+	fsys["postgres/000002_tags.up.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE tags (id SERIAL PRIMARY KEY, name TEXT, slug TEXT);")}
+	m, err = NewMigrator(db, "sqlite", fsys)
+	require.NoError(t, err)
+
+	_, err = m.Up(ctx)
+	assert.ErrorIs(t, err, ErrMigrationsModified)
+	status, err := m.Status(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, status.Modified)
+	assert.True(t, status.Migrations[1].Modified)
+
+	repaired, err := m.Repair(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, repaired)
+	applied, err := m.Up(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, applied)
+}
+
+func TestMigrator_BackfillsHistory(t *testing.T) {
+	db, err := sql.Open("sqlite3", "file::memory:")
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = db.Close() })
+	ctx := context.Background()
+
+	// A database migrated by the migrate CLI has a version but no history
+	_, err = db.Exec("CREATE TABLE schema_migrations (version BIGINT NOT NULL PRIMARY KEY, dirty BOOLEAN NOT NULL)")
+	require.NoError(t, err)
+	_, err = db.Exec("INSERT INTO schema_migrations (version, dirty) VALUES (2, 0)")
+	require.NoError(t, err)
+
+	m, err := NewMigrator(db, "sqlite", testMigrationsFS())
+	require.NoError(t, err)
+	status, err := m.Status(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, status.Pending)
+	assert.Nil(t, status.Migrations[0].AppliedAt)
+
+	_, err = db.Exec("CREATE TABLE notes (id INTEGER PRIMARY KEY AUTOINCREMENT, body TEXT)")
+	require.NoError(t, err)
+	applied, err := m.Up(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 1, applied)
+	status, err = m.Status(ctx)
+	require.NoError(t, err)
+	assert.NotNil(t, status.Migrations[0].AppliedAt)
+}
+
+func TestMigrator_Dirty(t *testing.T) {
+	db, err := sql.Open("sqlite3", "file::memory:")
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = db.Close() })
+	ctx := context.Background()
+
+	fsys := testMigrationsFS()
+	fsys["postgres/000002_tags.up.sql"] = &fstest.MapFile{Data: []byte("CREATE TABLE tags (id SERIAL PRIMARY KEY); SELECT * FROM missing;")}
+	m, err := NewMigrator(db, "sqlite", fsys)
+	require.NoError(t, err)
+
+	applied, err := m.Up(ctx)
+	assert.Error(t, err)
+	assert.Equal(t, 1, applied)
+	// The failed migration was rolled back as a whole
+	version, dirty, err := GetMigrationVersion(db)
+	require.NoError(t, err)
+	assert.Equal(t, uint(1), version)
+	assert.False(t, dirty)
+}
@@ -7,6 +7,8 @@ import (
 	"strings"
 	"time"
 	// _ "github.com/go-sql-driver/mysql" // TODO: Add when implementing MySQL support.
+
+	"github.com/goatkit/goatflow/internal/tracing"
 )
 
 // MySQLDatabase implements IDatabase for MySQL/MariaDB.
@@ -27,7 +29,7 @@ func (m *MySQLDatabase) Connect() error {
 	dsn := m.buildDSN()
 
 	var err error
-	m.db, err = sql.Open("mysql", dsn)
+	m.db, err = tracing.OpenDB("mysql", dsn)
 	if err != nil {
 		return fmt.Errorf("failed to open MySQL connection: %w", err)
 	}
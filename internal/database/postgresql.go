@@ -8,6 +8,8 @@ import (
 	"time"
 
 	_ "github.com/lib/pq"
+
+	"github.com/goatkit/goatflow/internal/tracing"
 )
 
 // PostgreSQLDatabase implements IDatabase for PostgreSQL.
@@ -28,7 +30,7 @@ func (p *PostgreSQLDatabase) Connect() error {
 	dsn := p.buildDSN()
 
 	var err error
-	p.db, err = sql.Open("postgres", dsn)
+	p.db, err = tracing.OpenDB("postgres", dsn)
 	if err != nil {
 		return fmt.Errorf("failed to open PostgreSQL connection: %w", err)
 	}
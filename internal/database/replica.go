@@ -0,0 +1,362 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/goatkit/goatflow/internal/tracing"
+)
+
+// Defaults for ReplicaSet.
+const (
+	DefaultReplicaMaxLag        = 10 * time.Second
+	DefaultReplicaCheckInterval = 5 * time.Second
+)
+
+// ReplicaConfig describes a read replica connection. Empty fields take the
+// value of the primary connection.
+type ReplicaConfig struct {
+	Name     string
+	Host     string
+	Port     int
+	User     string
+	Password string
+	Database string
+	SSLMode  string
+}
+
+// Replica is an open read replica.
+type Replica struct {
+	Name string
+	DB   *sql.DB
+}
+
+// ReplicaStatus is the result of the last health check of a replica.
+type ReplicaStatus struct {
+	Name      string        `json:"name"`
+	Healthy   bool          `json:"healthy"`
+	Lag       time.Duration `json:"lag"`
+	Error     string        `json:"error,omitempty"`
+	CheckedAt time.Time     `json:"checked_at"`
+}
+
+// LagFunc measures how far a replica is behind the primary.
+type LagFunc func(ctx context.Context, db *sql.DB) (time.Duration, error)
+
+// ReplicaSet routes read-only queries to replicas that answer their health
+// check and are no more than maxLag behind the primary. Until the first
+// check, and whenever no replica qualifies, reads go to the primary.
+type ReplicaSet struct {
+	replicas []Replica
+	maxLag   time.Duration
+	lag      LagFunc
+
+	mu     sync.RWMutex
+	status []ReplicaStatus
+	next   atomic.Uint64
+}
+
+// NewReplicaSet creates a set routing to replicas. A zero maxLag takes
+// DefaultReplicaMaxLag; a nil lag function takes ReplicationLag for the
+// configured driver.
+func NewReplicaSet(replicas []Replica, maxLag time.Duration, lag LagFunc) *ReplicaSet {
+	if maxLag <= 0 {
+		maxLag = DefaultReplicaMaxLag
+	}
+	if lag == nil {
+		driver := GetDBDriver()
+		lag = func(ctx context.Context, db *sql.DB) (time.Duration, error) {
+			return ReplicationLag(ctx, db, driver)
+		}
+	}
+	status := make([]ReplicaStatus, len(replicas))
+	for i, r := range replicas {
+		status[i] = ReplicaStatus{Name: r.Name}
+	}
+	return &ReplicaSet{replicas: replicas, maxLag: maxLag, lag: lag, status: status}
+}
+
+// Check probes every replica and records its health and lag.
+func (s *ReplicaSet) Check(ctx context.Context) {
+	status := make([]ReplicaStatus, len(s.replicas))
+	for i, r := range s.replicas {
+		status[i] = s.probe(ctx, r)
+		replicaMetricsFor().observe(status[i])
+	}
+	s.mu.Lock()
+	prev := s.status
+	s.status = status
+	s.mu.Unlock()
+
+	for i, st := range status {
+		switch {
+		case st.Healthy && !prev[i].Healthy && !prev[i].CheckedAt.IsZero():
+			log.Printf("database: replica %s is back in use for reads", st.Name)
+		case !st.Healthy && (prev[i].Healthy || prev[i].CheckedAt.IsZero()):
+			log.Printf("database: replica %s not used for reads: %s", st.Name, st.Error)
+		}
+	}
+}
+
+func (s *ReplicaSet) probe(ctx context.Context, r Replica) ReplicaStatus {
+	st := ReplicaStatus{Name: r.Name, CheckedAt: time.Now()}
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+
+	if err := r.DB.PingContext(ctx); err != nil {
+		st.Error = err.Error()
+		return st
+	}
+	lag, err := s.lag(ctx, r.DB)
+	if err != nil {
+		st.Error = err.Error()
+		return st
+	}
+	st.Lag = lag
+	if lag > s.maxLag {
+		st.Error = fmt.Sprintf("lag %s exceeds %s", lag.Round(time.Millisecond), s.maxLag)
+		return st
+	}
+	st.Healthy = true
+	return st
+}
+
+// Run checks the replicas every interval until ctx is done.
+func (s *ReplicaSet) Run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultReplicaCheckInterval
+	}
+	s.Check(ctx)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.Check(ctx)
+		}
+	}
+}
+
+// Pick returns a healthy replica in round-robin order, or nil when there
+// is none.
+func (s *ReplicaSet) Pick() *sql.DB {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	n := len(s.replicas)
+	if n == 0 {
+		return nil
+	}
+	start := int(s.next.Add(1) % uint64(n))
+	for i := 0; i < n; i++ {
+		idx := (start + i) % n
+		if s.status[idx].Healthy {
+			return s.replicas[idx].DB
+		}
+	}
+	return nil
+}
+
+// Status returns the result of the last check of every replica.
+func (s *ReplicaSet) Status() []ReplicaStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]ReplicaStatus(nil), s.status...)
+}
+
+// Close closes the replica connections.
+func (s *ReplicaSet) Close() error {
+	var errs []error
+	for _, r := range s.replicas {
+		errs = append(errs, r.DB.Close())
+	}
+	return errors.Join(errs...)
+}
+
+var (
+	replicasMu sync.RWMutex
+	replicas   *ReplicaSet
+)
+
+// SetReplicas sets the replicas GetReadDB routes to. Pass nil to send all
+// reads to the primary.
+func SetReplicas(s *ReplicaSet) {
+	replicasMu.Lock()
+	replicas = s
+	replicasMu.Unlock()
+}
+
+// Replicas returns the configured replica set, or nil.
+func Replicas() *ReplicaSet {
+	replicasMu.RLock()
+	defer replicasMu.RUnlock()
+	return replicas
+}
+
+// GetReadDB returns a connection for read-only queries: a healthy replica
+// when one is configured, the primary otherwise. Use it only where reading
+// slightly stale data is acceptable, such as ticket lists, searches and
+// dashboards; never for a read that follows the caller's own write.
+func GetReadDB() (*sql.DB, error) {
+	if IsTestDBOverride() {
+		return GetDB()
+	}
+	if s := Replicas(); s != nil {
+		if db := s.Pick(); db != nil {
+			return db, nil
+		}
+	}
+	return GetDB()
+}
+
+// OpenReplica opens a connection to the replica described by rc. Empty
+// fields take the primary's DB_* environment values.
+func OpenReplica(driver string, rc ReplicaConfig) (*sql.DB, error) {
+	if rc.Host == "" {
+		return nil, errors.New("replica host is required")
+	}
+	user := firstNonEmpty(rc.User, os.Getenv("DB_USER"))
+	password := firstNonEmpty(rc.Password, os.Getenv("DB_PASSWORD"))
+	name := firstNonEmpty(rc.Database, os.Getenv("DB_NAME"), "goatflow")
+
+	switch driver {
+	case "postgres", "postgresql":
+		port := rc.Port
+		if port == 0 {
+			port = 5432
+		}
+		sslMode := firstNonEmpty(rc.SSLMode, os.Getenv("DB_SSL_MODE"), "disable")
+		dsn := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s",
+			rc.Host, port, user, password, name, sslMode)
+		return tracing.OpenDB("postgres", dsn)
+	case "mysql", "mariadb":
+		port := rc.Port
+		if port == 0 {
+			port = 3306
+		}
+		dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?parseTime=true",
+			user, password, rc.Host, port, name)
+		return tracing.OpenDB("mysql", dsn)
+	default:
+		return nil, fmt.Errorf("read replicas are not supported for driver %s", driver)
+	}
+}
+
+// ReplicationLag returns how far the replica behind db is behind its
+// primary. A server that is not replicating reports no lag.
+func ReplicationLag(ctx context.Context, db *sql.DB, driver string) (time.Duration, error) {
+	switch driver {
+	case "postgres", "postgresql":
+		// An idle primary sends no transactions to replay, so a replica that
+		// has replayed everything it received is current.
+		var seconds float64
+		err := db.QueryRowContext(ctx, `
+			SELECT CASE
+				WHEN NOT pg_is_in_recovery() THEN 0
+				WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+				ELSE COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0)
+			END`).Scan(&seconds)
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(seconds * float64(time.Second)), nil
+	case "mysql", "mariadb":
+		return mysqlReplicationLag(ctx, db)
+	default:
+		return 0, nil
+	}
+}
+
+// mysqlReplicationLag reads Seconds_Behind_Source from SHOW REPLICA STATUS,
+// falling back to SHOW SLAVE STATUS on servers that predate it.
+func mysqlReplicationLag(ctx context.Context, db *sql.DB) (time.Duration, error) {
+	rows, err := db.QueryContext(ctx, "SHOW REPLICA STATUS")
+	if err != nil {
+		rows, err = db.QueryContext(ctx, "SHOW SLAVE STATUS")
+		if err != nil {
+			return 0, err
+		}
+	}
+	defer rows.Close()
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return 0, err
+	}
+	if !rows.Next() {
+		return 0, rows.Err()
+	}
+	values := make([]sql.NullString, len(cols))
+	dest := make([]interface{}, len(cols))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+	if err := rows.Scan(dest...); err != nil {
+		return 0, err
+	}
+	for i, col := range cols {
+		if !strings.EqualFold(col, "Seconds_Behind_Source") && !strings.EqualFold(col, "Seconds_Behind_Master") {
+			continue
+		}
+		if !values[i].Valid {
+			return 0, errors.New("replication is not running")
+		}
+		var seconds int64
+		if _, err := fmt.Sscan(values[i].String, &seconds); err != nil {
+			return 0, fmt.Errorf("parse %s: %w", col, err)
+		}
+		return time.Duration(seconds) * time.Second, nil
+	}
+	return 0, errors.New("replica status has no lag column")
+}
+
+type replicaMetrics struct {
+	lag     *prometheus.GaugeVec
+	healthy *prometheus.GaugeVec
+}
+
+var (
+	replicaMetricsOnce sync.Once
+	replicaMetricsInst *replicaMetrics
+)
+
+func replicaMetricsFor() *replicaMetrics {
+	replicaMetricsOnce.Do(func() {
+		replicaMetricsInst = &replicaMetrics{
+			lag: promauto.NewGaugeVec(prometheus.GaugeOpts{
+				Namespace: "goatflow",
+				Subsystem: "db_replica",
+				Name:      "lag_seconds",
+				Help:      "Replication lag of each read replica at its last health check",
+			}, []string{"replica"}),
+			healthy: promauto.NewGaugeVec(prometheus.GaugeOpts{
+				Namespace: "goatflow",
+				Subsystem: "db_replica",
+				Name:      "healthy",
+				Help:      "1 when the read replica receives read-only queries, 0 when they go to the primary",
+			}, []string{"replica"}),
+		}
+	})
+	return replicaMetricsInst
+}
+
+func (m *replicaMetrics) observe(st ReplicaStatus) {
+	m.lag.WithLabelValues(st.Name).Set(st.Lag.Seconds())
+	healthy := 0.0
+	if st.Healthy {
+		healthy = 1
+	}
+	m.healthy.WithLabelValues(st.Name).Set(healthy)
+}
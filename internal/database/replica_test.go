@@ -0,0 +1,117 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/goatkit/goatflow/migrations"
+)
+
+// openReplicaTestDB opens an in-memory SQLite replica carrying the migrated
+// schema. testutil.MigratedDB cannot be used here as it imports this package.
+func openReplicaTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("sqlite3", "file::memory:?_foreign_keys=on")
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = db.Close() })
+
+	m, err := NewMigrator(db, "sqlite", migrations.FS)
+	if err != nil {
+		t.Fatalf("load migrations: %v", err)
+	}
+	if _, err := m.Up(context.Background()); err != nil {
+		t.Fatalf("migrate replica: %v", err)
+	}
+	return db
+}
+
+func TestReplicaSet_RoutesToHealthyReplicas(t *testing.T) {
+	a, b := openReplicaTestDB(t), openReplicaTestDB(t)
+	s := NewReplicaSet([]Replica{{Name: "a", DB: a}, {Name: "b", DB: b}}, time.Second,
+		func(context.Context, *sql.DB) (time.Duration, error) { return 0, nil })
+
+	if db := s.Pick(); db != nil {
+		t.Fatal("replicas must not be used before the first check")
+	}
+	s.Check(context.Background())
+
+	seen := map[*sql.DB]bool{}
+	for i := 0; i < 4; i++ {
+		seen[s.Pick()] = true
+	}
+	if !seen[a] || !seen[b] || len(seen) != 2 {
+		t.Errorf("reads should alternate between both replicas, got %d distinct", len(seen))
+	}
+}
+
+func TestReplicaSet_SkipsLaggingAndFailingReplicas(t *testing.T) {
+	current, lagging, failing := openReplicaTestDB(t), openReplicaTestDB(t), openReplicaTestDB(t)
+	s := NewReplicaSet([]Replica{
+		{Name: "current", DB: current},
+		{Name: "lagging", DB: lagging},
+		{Name: "failing", DB: failing},
+	}, time.Second, func(_ context.Context, db *sql.DB) (time.Duration, error) {
+		switch db {
+		case lagging:
+			return time.Minute, nil
+		case failing:
+			return 0, errors.New("replication is not running")
+		}
+		return 100 * time.Millisecond, nil
+	})
+	s.Check(context.Background())
+
+	for i := 0; i < 6; i++ {
+		if db := s.Pick(); db != current {
+			t.Fatalf("pick %d did not return the current replica", i)
+		}
+	}
+	status := s.Status()
+	if !status[0].Healthy || status[0].Lag != 100*time.Millisecond {
+		t.Errorf("current: %+v", status[0])
+	}
+	if status[1].Healthy || status[1].Error == "" {
+		t.Errorf("lagging replica should be reported: %+v", status[1])
+	}
+	if status[2].Healthy || status[2].Error != "replication is not running" {
+		t.Errorf("failing replica should be reported: %+v", status[2])
+	}
+}
+
+func TestReplicaSet_FallsBackWhenReplicaIsDown(t *testing.T) {
+	db := openReplicaTestDB(t)
+	s := NewReplicaSet([]Replica{{Name: "only", DB: db}}, 0,
+		func(context.Context, *sql.DB) (time.Duration, error) { return 0, nil })
+	s.Check(context.Background())
+	if s.Pick() != db {
+		t.Fatal("healthy replica should be used")
+	}
+
+	_ = db.Close()
+	s.Check(context.Background())
+	if s.Pick() != nil {
+		t.Error("closed replica must not be used")
+	}
+}
+
+func TestGetReadDB_PrefersReplica(t *testing.T) {
+	replica := openReplicaTestDB(t)
+	s := NewReplicaSet([]Replica{{Name: "r", DB: replica}}, 0,
+		func(context.Context, *sql.DB) (time.Duration, error) { return 0, nil })
+	s.Check(context.Background())
+	SetReplicas(s)
+	t.Cleanup(func() { SetReplicas(nil) })
+
+	db, err := GetReadDB()
+	if err != nil || db != replica {
+		t.Errorf("GetReadDB() = %p, %v; want the replica", db, err)
+	}
+}
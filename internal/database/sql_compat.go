@@ -5,6 +5,7 @@ import (
 	"os"
 	"regexp"
 	"strings"
+	"sync"
 )
 
 // GetDBDriver returns the current database driver.
@@ -31,6 +32,12 @@ func IsPostgreSQL() bool {
 	return GetDBDriver() == "postgres"
 }
 
+// IsSQLite returns true if using SQLite.
+func IsSQLite() bool {
+	driver := GetDBDriver()
+	return driver == "sqlite" || driver == "sqlite3"
+}
+
 // TicketTypeColumn returns the ticket type column name for the active driver.
 func TicketTypeColumn() string {
 	return "type_id"
@@ -52,21 +59,57 @@ func QualifiedTicketTypeColumn(alias string) string {
 // IMPORTANT: Only ? placeholders are allowed. Using $N placeholders will panic.
 // - For PostgreSQL: ? → $1, $2, ...
 // - For MySQL: ? passed through as-is
+// - For SQLite: ? passed through as-is, NOW() → CURRENT_TIMESTAMP
 //
 // Example:
 //
 //	query := database.ConvertPlaceholders("SELECT * FROM users WHERE id = ? AND name = ?")
 //	rows, err := db.Query(query, id, name)
 func ConvertPlaceholders(query string) string {
+	dialect := "pg"
+	switch {
+	case IsMySQL():
+		dialect = "mysql"
+	case IsSQLite():
+		dialect = "sqlite"
+	}
+	key := dialect + "\x00" + query
+	convertedMu.RLock()
+	result, ok := converted[key]
+	convertedMu.RUnlock()
+	if ok {
+		return result
+	}
+
 	// Reject $N placeholders - all queries must use ? for portability
-	if regexp.MustCompile(`\$\d+`).MatchString(query) {
+	if dollarPlaceholder.MatchString(query) {
 		panic(fmt.Sprintf("ConvertPlaceholders: $N placeholders are not allowed. Use ? placeholders instead.\nQuery: %s", query))
 	}
+	result = convertPlaceholders(query, dialect)
 
-	if IsMySQL() {
-		// ? placeholders work directly for MySQL
-		// No conversion needed
-	} else {
+	convertedMu.Lock()
+	if len(converted) >= maxConvertedQueries {
+		// Queries built with literal values never repeat; start over
+		// rather than grow without bound.
+		converted = make(map[string]string)
+	}
+	converted[key] = result
+	convertedMu.Unlock()
+	return result
+}
+
+// Converted queries by driver and query. Handlers run the same statements
+// constantly, so each is converted once.
+const maxConvertedQueries = 4096
+
+var (
+	dollarPlaceholder = regexp.MustCompile(`\$\d+`)
+	convertedMu       sync.RWMutex
+	converted         = make(map[string]string)
+)
+
+func convertPlaceholders(query, dialect string) string {
+	if dialect == "pg" {
 		// PostgreSQL uses $1, $2, etc.
 		if strings.Contains(query, "?") {
 			// Convert ? to $1, $2, etc.
@@ -82,12 +125,18 @@ func ConvertPlaceholders(query string) string {
 			}
 			query = result.String()
 		}
+		return query
 	}
+	// ? placeholders work directly for MySQL and SQLite
 
-	// Convert ILIKE to LIKE for MySQL (MySQL is case-insensitive by default with utf8_general_ci)
-	if IsMySQL() {
-		query = strings.ReplaceAll(query, " ILIKE ", " LIKE ")
-		query = strings.ReplaceAll(query, " ilike ", " LIKE ")
+	// Convert ILIKE to LIKE (MySQL is case-insensitive by default with utf8_general_ci,
+	// SQLite's LIKE ignores case for ASCII)
+	query = strings.ReplaceAll(query, " ILIKE ", " LIKE ")
+	query = strings.ReplaceAll(query, " ilike ", " LIKE ")
+
+	if dialect == "sqlite" {
+		query = strings.ReplaceAll(query, "NOW()", "CURRENT_TIMESTAMP")
+		query = strings.ReplaceAll(query, "now()", "CURRENT_TIMESTAMP")
 	}
 
 	return query
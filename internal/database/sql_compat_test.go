@@ -0,0 +1,29 @@
+package database
+
+import "testing"
+
+func TestConvertPlaceholders(t *testing.T) {
+	query := "SELECT id FROM users WHERE login ILIKE ? AND valid_id = ?"
+
+	t.Setenv("TEST_DB_DRIVER", "postgres")
+	for i := 0; i < 2; i++ {
+		if got, want := ConvertPlaceholders(query), "SELECT id FROM users WHERE login ILIKE $1 AND valid_id = $2"; got != want {
+			t.Errorf("postgres: got %q, want %q", got, want)
+		}
+	}
+
+	// Converted queries are cached per driver
+	t.Setenv("TEST_DB_DRIVER", "mysql")
+	if got, want := ConvertPlaceholders(query), "SELECT id FROM users WHERE login LIKE ? AND valid_id = ?"; got != want {
+		t.Errorf("mysql: got %q, want %q", got, want)
+	}
+}
+
+func TestConvertPlaceholders_RejectsDollarPlaceholders(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic for $N placeholders")
+		}
+	}()
+	ConvertPlaceholders("SELECT id FROM users WHERE id = $1")
+}
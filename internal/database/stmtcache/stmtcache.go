@@ -0,0 +1,518 @@
+// Package stmtcache wraps database driver connections with a cache of
+// prepared statements and a slow query log.
+//
+// Handlers run the same handful of statements over and over, each time
+// as a fresh query that the server parses and plans again. The wrapped
+// connection prepares a statement the first time it sees it and reuses it
+// afterwards. Statements are keyed by their normalized text, so queries
+// that differ only in whitespace share an entry.
+//
+// Statements slower than the configured threshold are logged. In debug
+// mode the log includes the plan the database reports for slow SELECTs.
+package stmtcache
+
+import (
+	"container/list"
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Defaults used until Configure is called.
+const (
+	DefaultSize          = 128
+	DefaultSlowThreshold = 500 * time.Millisecond
+)
+
+// maxCachedQueryLength keeps generated statements, such as long IN lists,
+// out of the cache.
+const maxCachedQueryLength = 8 << 10
+
+// Options configures every wrapped connection.
+type Options struct {
+	// Size is the number of statements cached per connection. Zero turns
+	// the cache off.
+	Size int
+	// SlowThreshold is the duration from starting a statement to closing
+	// its result after which the statement is logged. Zero turns the slow
+	// query log off.
+	SlowThreshold time.Duration
+	// Explain adds the query plan of slow SELECT statements to the log.
+	Explain bool
+}
+
+var options atomic.Pointer[Options]
+
+func init() {
+	options.Store(&Options{Size: DefaultSize, SlowThreshold: DefaultSlowThreshold})
+}
+
+// Configure replaces the options of all wrapped connections, including
+// open ones.
+func Configure(o Options) {
+	if o.Size < 0 {
+		o.Size = 0
+	}
+	options.Store(&o)
+}
+
+func current() Options { return *options.Load() }
+
+// logf writes the slow query log; tests replace it.
+var logf = log.Printf
+
+// Wrap returns a connector whose connections cache prepared statements and
+// log slow statements. system names the database, e.g. "postgres".
+func Wrap(c driver.Connector, system string) driver.Connector {
+	return &connector{base: c, system: system}
+}
+
+type connector struct {
+	base   driver.Connector
+	system string
+}
+
+func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.base.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return newConn(conn, c.system), nil
+}
+
+func (c *connector) Driver() driver.Driver { return c.base.Driver() }
+
+// Normalize collapses runs of whitespace outside quoted strings,
+// identifiers and line comments into single spaces and trims the
+// statement.
+func Normalize(query string) string {
+	var b strings.Builder
+	b.Grow(len(query))
+	var quote rune
+	escaped, comment, space := false, false, false
+	var prev rune
+	for _, r := range strings.TrimSpace(query) {
+		switch {
+		case comment:
+			// A line comment runs to the end of the line, so its newline stays
+			if r == '\n' {
+				comment = false
+			}
+		case quote != 0:
+			switch {
+			case escaped:
+				escaped = false
+			case r == '\\':
+				escaped = true
+			case r == quote:
+				quote = 0
+			}
+		case r == '\'' || r == '"' || r == '`':
+			quote = r
+		case r == '-' && prev == '-':
+			comment = true
+		case unicode.IsSpace(r):
+			space, prev = true, r
+			continue
+		}
+		if space {
+			b.WriteByte(' ')
+			space = false
+		}
+		b.WriteRune(r)
+		prev = r
+	}
+	return b.String()
+}
+
+// cacheable reports whether a statement is worth preparing: a single DML
+// statement with arguments. Statements without arguments, DDL and session
+// commands run as before.
+func cacheable(query string, nargs int) bool {
+	if nargs == 0 || len(query) > maxCachedQueryLength {
+		return false
+	}
+	switch operation(query) {
+	case "SELECT", "INSERT", "UPDATE", "DELETE", "WITH":
+		return !strings.Contains(strings.TrimRight(query, "; "), ";")
+	}
+	return false
+}
+
+// operation returns the statement's first keyword, e.g. SELECT.
+func operation(query string) string {
+	fields := strings.Fields(query)
+	if len(fields) == 0 {
+		return "SQL"
+	}
+	return strings.ToUpper(strings.TrimLeft(fields[0], "("))
+}
+
+// staleStatement reports errors after which a cached statement must be
+// prepared again, such as a schema change under a cached plan.
+func staleStatement(err error) bool {
+	msg := err.Error()
+	return strings.Contains(msg, "cached plan must not change result type") || // PostgreSQL
+		strings.Contains(msg, "needs to be re-prepared") // MySQL 1615
+}
+
+// conn wraps a driver connection. database/sql uses a connection from one
+// goroutine at a time, so the statement cache needs no locking. Like the
+// tracing wrapper, it implements every optional interface database/sql
+// looks for and falls back the way database/sql would.
+type conn struct {
+	driver.Conn
+	system string
+
+	lru   *list.List // Front is the most recently used
+	stmts map[string]*list.Element
+}
+
+type cachedStmt struct {
+	key  string
+	stmt driver.Stmt
+}
+
+func newConn(c driver.Conn, system string) *conn {
+	return &conn{Conn: c, system: system, lru: list.New(), stmts: make(map[string]*list.Element)}
+}
+
+// stmt returns the cached statement for query, preparing it on a miss.
+// It returns nil when the statement is not cached.
+func (c *conn) stmt(ctx context.Context, query string, nargs int, size int) (key string, _ driver.Stmt) {
+	if size == 0 || !cacheable(query, nargs) {
+		return "", nil
+	}
+	key = Normalize(query)
+	if el, ok := c.stmts[key]; ok {
+		c.lru.MoveToFront(el)
+		metricsFor().cache.WithLabelValues("hit").Inc()
+		return key, el.Value.(*cachedStmt).stmt
+	}
+	metricsFor().cache.WithLabelValues("miss").Inc()
+
+	var stmt driver.Stmt
+	var err error
+	if preparer, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		stmt, err = preparer.PrepareContext(ctx, key)
+	} else {
+		stmt, err = c.Conn.Prepare(key)
+	}
+	if err != nil {
+		// Run the statement unprepared; its own error, if any, reaches
+		// the caller from there.
+		return "", nil
+	}
+	c.stmts[key] = c.lru.PushFront(&cachedStmt{key: key, stmt: stmt})
+	for c.lru.Len() > size {
+		c.evict(c.lru.Back().Value.(*cachedStmt).key)
+	}
+	return key, stmt
+}
+
+func (c *conn) evict(key string) {
+	el, ok := c.stmts[key]
+	if !ok {
+		return
+	}
+	c.lru.Remove(el)
+	delete(c.stmts, key)
+	_ = el.Value.(*cachedStmt).stmt.Close()
+}
+
+func (c *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	opts := current()
+	start := time.Now()
+	var res driver.Result
+	var err error
+	if key, stmt := c.stmt(ctx, query, len(args), opts.Size); stmt != nil {
+		if execer, ok := stmt.(driver.StmtExecContext); ok {
+			res, err = execer.ExecContext(ctx, args)
+		} else {
+			res, err = stmt.Exec(namedValues(args)) //nolint:staticcheck // fallback for drivers without ExecContext
+		}
+		if err != nil && staleStatement(err) {
+			c.evict(key)
+		}
+	} else {
+		execer, ok := c.Conn.(driver.ExecerContext)
+		if !ok {
+			return nil, driver.ErrSkip
+		}
+		res, err = execer.ExecContext(ctx, query, args)
+		if err == driver.ErrSkip {
+			return nil, err
+		}
+	}
+	c.observe(opts, query, args, time.Since(start), false)
+	return res, err
+}
+
+func (c *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	opts := current()
+	start := time.Now()
+	var rows driver.Rows
+	var err error
+	if key, stmt := c.stmt(ctx, query, len(args), opts.Size); stmt != nil {
+		if queryer, ok := stmt.(driver.StmtQueryContext); ok {
+			rows, err = queryer.QueryContext(ctx, args)
+		} else {
+			rows, err = stmt.Query(namedValues(args)) //nolint:staticcheck // fallback for drivers without QueryContext
+		}
+		if err != nil && staleStatement(err) {
+			c.evict(key)
+		}
+	} else {
+		queryer, ok := c.Conn.(driver.QueryerContext)
+		if !ok {
+			return nil, driver.ErrSkip
+		}
+		rows, err = queryer.QueryContext(ctx, query, args)
+		if err == driver.ErrSkip {
+			return nil, err
+		}
+	}
+	if err != nil {
+		c.observe(opts, query, args, time.Since(start), false)
+		return nil, err
+	}
+	if opts.SlowThreshold <= 0 {
+		return rows, nil
+	}
+	return &timedRows{Rows: rows, conn: c, opts: opts, query: query, args: args, start: start}, nil
+}
+
+func (c *conn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	if preparer, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		return preparer.PrepareContext(ctx, query)
+	}
+	return c.Conn.Prepare(query)
+}
+
+func (c *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if beginner, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return beginner.BeginTx(ctx, opts)
+	}
+	return c.Conn.Begin() //nolint:staticcheck // fallback for drivers without BeginTx
+}
+
+func (c *conn) Ping(ctx context.Context) error {
+	if pinger, ok := c.Conn.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *conn) ResetSession(ctx context.Context) error {
+	if resetter, ok := c.Conn.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *conn) IsValid() bool {
+	if validator, ok := c.Conn.(driver.Validator); ok {
+		return validator.IsValid()
+	}
+	return true
+}
+
+func (c *conn) CheckNamedValue(nv *driver.NamedValue) error {
+	if checker, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+// Close closes the cached statements, then the connection.
+func (c *conn) Close() error {
+	for key := range c.stmts {
+		c.evict(key)
+	}
+	return c.Conn.Close()
+}
+
+// observe logs a statement that took longer than the slow query threshold.
+// explain is only set once the statement's rows are closed, when the
+// connection is free to run EXPLAIN.
+func (c *conn) observe(opts Options, query string, args []driver.NamedValue, elapsed time.Duration, explain bool) {
+	if opts.SlowThreshold <= 0 || elapsed < opts.SlowThreshold {
+		return
+	}
+	op := operation(query)
+	metricsFor().slow.WithLabelValues(op).Inc()
+	msg := fmt.Sprintf("database: slow %s (%s, %d args): %s",
+		op, elapsed.Round(time.Millisecond), len(args), truncate(Normalize(query), 1000))
+	if explain && opts.Explain && op == "SELECT" {
+		if plan, err := c.explain(query, args); err != nil {
+			msg += "\n  EXPLAIN failed: " + err.Error()
+		} else {
+			msg += "\n" + plan
+		}
+	}
+	logf("%s", msg)
+}
+
+// explain returns the plan of query with the same arguments.
+func (c *conn) explain(query string, args []driver.NamedValue) (string, error) {
+	queryer, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return "", errors.New("driver cannot run queries directly")
+	}
+	prefix := "EXPLAIN "
+	if c.system == "sqlite3" {
+		prefix = "EXPLAIN QUERY PLAN "
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	rows, err := queryer.QueryContext(ctx, prefix+query, args)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	values := make([]driver.Value, len(rows.Columns()))
+	var lines []string
+	for {
+		if err := rows.Next(values); err == io.EOF {
+			break
+		} else if err != nil {
+			return "", err
+		}
+		parts := make([]string, len(values))
+		for i, v := range values {
+			if b, ok := v.([]byte); ok {
+				v = string(b)
+			}
+			parts[i] = fmt.Sprint(v)
+		}
+		lines = append(lines, "  "+strings.Join(parts, " | "))
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// timedRows measures a query until its rows are closed, so time spent
+// streaming results counts towards the slow query threshold.
+type timedRows struct {
+	driver.Rows
+	conn  *conn
+	opts  Options
+	query string
+	args  []driver.NamedValue
+	start time.Time
+}
+
+func (r *timedRows) Close() error {
+	err := r.Rows.Close()
+	r.conn.observe(r.opts, r.query, r.args, time.Since(r.start), err == nil)
+	return err
+}
+
+// The optional Rows interfaces fall back to what database/sql assumes when
+// a driver does not implement them.
+
+func (r *timedRows) HasNextResultSet() bool {
+	if n, ok := r.Rows.(driver.RowsNextResultSet); ok {
+		return n.HasNextResultSet()
+	}
+	return false
+}
+
+func (r *timedRows) NextResultSet() error {
+	if n, ok := r.Rows.(driver.RowsNextResultSet); ok {
+		return n.NextResultSet()
+	}
+	return io.EOF
+}
+
+func (r *timedRows) ColumnTypeScanType(index int) reflect.Type {
+	if t, ok := r.Rows.(driver.RowsColumnTypeScanType); ok {
+		return t.ColumnTypeScanType(index)
+	}
+	return reflect.TypeOf(new(any)).Elem()
+}
+
+func (r *timedRows) ColumnTypeDatabaseTypeName(index int) string {
+	if t, ok := r.Rows.(driver.RowsColumnTypeDatabaseTypeName); ok {
+		return t.ColumnTypeDatabaseTypeName(index)
+	}
+	return ""
+}
+
+func (r *timedRows) ColumnTypeLength(index int) (int64, bool) {
+	if t, ok := r.Rows.(driver.RowsColumnTypeLength); ok {
+		return t.ColumnTypeLength(index)
+	}
+	return 0, false
+}
+
+func (r *timedRows) ColumnTypeNullable(index int) (nullable, ok bool) {
+	if t, ok := r.Rows.(driver.RowsColumnTypeNullable); ok {
+		return t.ColumnTypeNullable(index)
+	}
+	return false, false
+}
+
+func (r *timedRows) ColumnTypePrecisionScale(index int) (precision, scale int64, ok bool) {
+	if t, ok := r.Rows.(driver.RowsColumnTypePrecisionScale); ok {
+		return t.ColumnTypePrecisionScale(index)
+	}
+	return 0, 0, false
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
+
+func namedValues(args []driver.NamedValue) []driver.Value {
+	values := make([]driver.Value, len(args))
+	for i, arg := range args {
+		values[i] = arg.Value
+	}
+	return values
+}
+
+type stmtMetrics struct {
+	cache *prometheus.CounterVec
+	slow  *prometheus.CounterVec
+}
+
+var (
+	metricsOnce sync.Once
+	metricsInst *stmtMetrics
+)
+
+func metricsFor() *stmtMetrics {
+	metricsOnce.Do(func() {
+		metricsInst = &stmtMetrics{
+			cache: promauto.NewCounterVec(prometheus.CounterOpts{
+				Namespace: "goatflow",
+				Subsystem: "db",
+				Name:      "statement_cache_total",
+				Help:      "Prepared statement cache lookups, labeled by result (hit or miss)",
+			}, []string{"result"}),
+			slow: promauto.NewCounterVec(prometheus.CounterOpts{
+				Namespace: "goatflow",
+				Subsystem: "db",
+				Name:      "slow_queries_total",
+				Help:      "Statements slower than the slow query threshold, labeled by operation",
+			}, []string{"operation"}),
+		}
+	})
+	return metricsInst
+}
@@ -0,0 +1,148 @@
+package stmtcache
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	sqlite3 "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type dsnConnector struct{ dsn string }
+
+func (c dsnConnector) Connect(context.Context) (driver.Conn, error) {
+	return (&sqlite3.SQLiteDriver{}).Open(c.dsn)
+}
+func (c dsnConnector) Driver() driver.Driver { return &sqlite3.SQLiteDriver{} }
+
+func openTestDB(t *testing.T, o Options) *sql.DB {
+	t.Helper()
+	prev := current()
+	Configure(o)
+	t.Cleanup(func() { Configure(prev) })
+
+	db := sql.OpenDB(Wrap(dsnConnector{dsn: ":memory:"}, "sqlite3"))
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	_, err := db.Exec(`CREATE TABLE ticket (id INTEGER PRIMARY KEY, title TEXT)`)
+	require.NoError(t, err)
+	return db
+}
+
+// cached returns the keys cached on db's only connection.
+func cached(t *testing.T, db *sql.DB) []string {
+	t.Helper()
+	c, err := db.Conn(context.Background())
+	require.NoError(t, err)
+	defer c.Close()
+	var keys []string
+	require.NoError(t, c.Raw(func(dc any) error {
+		for el := dc.(*conn).lru.Front(); el != nil; el = el.Next() {
+			keys = append(keys, el.Value.(*cachedStmt).key)
+		}
+		return nil
+	}))
+	return keys
+}
+
+func TestNormalize(t *testing.T) {
+	assert.Equal(t, "SELECT id FROM ticket WHERE title = ?",
+		Normalize("\n\tSELECT id\n\t  FROM ticket\n\tWHERE title = ?  "))
+	assert.Equal(t, "SELECT 'a  b' FROM x", Normalize("SELECT 'a  b'   FROM x"))
+	assert.Equal(t, `SELECT 'it\'s  here' FROM x`, Normalize(`SELECT 'it\'s  here'  FROM x`))
+	assert.Equal(t, "SELECT id -- newest first\n FROM x", Normalize("SELECT id -- newest first\n  FROM x"))
+	assert.Equal(t, "SELECT a - -1 FROM x", Normalize("SELECT a -  -1 FROM x"))
+}
+
+func TestCacheable(t *testing.T) {
+	assert.True(t, cacheable("SELECT * FROM ticket WHERE id = ?", 1))
+	assert.True(t, cacheable("  (WITH x AS (SELECT 1) SELECT * FROM x WHERE a = ?)", 1))
+	assert.False(t, cacheable("SELECT * FROM ticket", 0), "statements without arguments run as before")
+	assert.False(t, cacheable("CREATE TABLE x (id INT)", 1))
+	assert.False(t, cacheable("UPDATE a SET x = ?; UPDATE b SET y = 1", 1))
+	assert.True(t, cacheable("UPDATE a SET x = ?;", 1))
+}
+
+func TestConn_ReusesPreparedStatements(t *testing.T) {
+	db := openTestDB(t, Options{Size: 2})
+
+	for i := 0; i < 3; i++ {
+		_, err := db.Exec("INSERT INTO ticket (title) VALUES (?)", fmt.Sprint("t", i))
+		require.NoError(t, err)
+		var title string
+		require.NoError(t, db.QueryRow("SELECT title FROM ticket\n  WHERE id = ?", i+1).Scan(&title))
+		assert.Equal(t, fmt.Sprint("t", i), title)
+		// Same statement, other whitespace
+		require.NoError(t, db.QueryRow("SELECT title FROM ticket WHERE id = ?", i+1).Scan(&title))
+	}
+	assert.Equal(t, []string{
+		"SELECT title FROM ticket WHERE id = ?",
+		"INSERT INTO ticket (title) VALUES (?)",
+	}, cached(t, db))
+
+	// A third statement evicts the least recently used one
+	var n int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM ticket WHERE id > ?", 0).Scan(&n))
+	assert.Equal(t, 3, n)
+	assert.Equal(t, []string{
+		"SELECT COUNT(*) FROM ticket WHERE id > ?",
+		"SELECT title FROM ticket WHERE id = ?",
+	}, cached(t, db))
+}
+
+func TestConn_CacheDisabled(t *testing.T) {
+	db := openTestDB(t, Options{Size: 0})
+
+	_, err := db.Exec("INSERT INTO ticket (title) VALUES (?)", "x")
+	require.NoError(t, err)
+	assert.Empty(t, cached(t, db))
+}
+
+func TestConn_WorksInTransactions(t *testing.T) {
+	db := openTestDB(t, Options{Size: 8})
+
+	tx, err := db.Begin()
+	require.NoError(t, err)
+	_, err = tx.Exec("INSERT INTO ticket (title) VALUES (?)", "rolled back")
+	require.NoError(t, err)
+	require.NoError(t, tx.Rollback())
+
+	var n int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM ticket WHERE title = ?", "rolled back").Scan(&n))
+	assert.Zero(t, n)
+}
+
+func TestConn_LogsSlowQueriesWithPlan(t *testing.T) {
+	var logged []string
+	prevLogf := logf
+	logf = func(format string, args ...any) { logged = append(logged, fmt.Sprintf(format, args...)) }
+	t.Cleanup(func() { logf = prevLogf })
+
+	db := openTestDB(t, Options{Size: 8, SlowThreshold: time.Nanosecond, Explain: true})
+	logged = nil
+
+	rows, err := db.Query("SELECT id, title FROM ticket WHERE id = ?", 1)
+	require.NoError(t, err)
+	cols, err := rows.ColumnTypes()
+	require.NoError(t, err)
+	assert.Len(t, cols, 2)
+	require.NoError(t, rows.Close())
+
+	require.Len(t, logged, 1)
+	assert.Contains(t, logged[0], "slow SELECT")
+	assert.Contains(t, logged[0], "SELECT id, title FROM ticket WHERE id = ?")
+	assert.True(t, strings.Contains(logged[0], "SEARCH") || strings.Contains(logged[0], "USING"),
+		"plan should be logged: %s", logged[0])
+
+	logged = nil
+	Configure(Options{Size: 8, SlowThreshold: time.Hour})
+	_, err = db.Exec("INSERT INTO ticket (title) VALUES (?)", "fast")
+	require.NoError(t, err)
+	assert.Empty(t, logged)
+}
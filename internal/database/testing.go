@@ -26,8 +26,8 @@ func IsTestDBOverride() bool {
 
 // InitTestDB initializes a database connection for tests using the
 // project service adapter. It is safe to call multiple times.
-// It does not create schema by default; individual tests should
-// create required tables with CREATE TABLE IF NOT EXISTS as needed.
+// A SQLite test database is migrated here, since nothing else sets it up.
+// Other drivers are expected to be migrated already.
 func InitTestDB() error {
 	// In test environment with no DB configured, fast-return success (DB-less)
 	if v := os.Getenv("APP_ENV"); v == "test" {
@@ -54,6 +54,11 @@ func InitTestDB() error {
 	if err := db.Ping(); err != nil {
 		return err
 	}
+	if IsSQLite() {
+		if _, err := RunMigrations(db); err != nil {
+			return fmt.Errorf("migrate test database: %w", err)
+		}
+	}
 	// Keep a reference for CloseTestDB (no-op close semantics)
 	testDBMu.Lock()
 	testDB = db
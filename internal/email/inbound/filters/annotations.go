@@ -11,4 +11,8 @@ const (
 	AnnotationIgnoreMessage        = "postmaster.ignore_message"
 	AnnotationFollowUpTicketNumber = "postmaster.follow_up_ticket_number"
 	AnnotationTrustedHeaderPrefix  = "postmaster.trusted_header."
+	AnnotationNoAutoResponse       = "postmaster.no_auto_response"
+	AnnotationLoopDetected         = "postmaster.loop_detected"
+	AnnotationBounceType           = "postmaster.bounce_type"
+	AnnotationBounceRecipient      = "postmaster.bounce_recipient"
 )
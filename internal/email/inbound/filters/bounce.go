@@ -0,0 +1,251 @@
+package filters
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"net/textproto"
+	"regexp"
+	"strings"
+
+	"github.com/goatkit/goatflow/internal/models"
+)
+
+// BounceRecorder stores the bounces the bounce filter detects.
+type BounceRecorder interface {
+	RecordBounce(ctx context.Context, report models.EmailBounce) (*models.EmailBounce, error)
+}
+
+// BounceFilter detects delivery failure reports, classifies them as hard
+// (permanent) or soft (temporary) bounces and records them for the failed
+// recipients. Auto responses to bounces are suppressed.
+type BounceFilter struct {
+	recorder BounceRecorder
+	logger   *log.Logger
+}
+
+// NewBounceFilter creates a bounce filter.
+func NewBounceFilter(recorder BounceRecorder, logger *log.Logger) *BounceFilter {
+	return &BounceFilter{recorder: recorder, logger: logger}
+}
+
+// ID returns the filter identifier.
+func (f *BounceFilter) ID() string { return "bounce" }
+
+// maxBounceDepth limits how deep multipart bounces are searched for a
+// delivery status report.
+const maxBounceDepth = 3
+
+var bounceStatusPattern = regexp.MustCompile(`\b([245])\.\d{1,3}\.\d{1,3}\b`)
+
+// Apply records and annotates delivery failure reports.
+func (f *BounceFilter) Apply(ctx context.Context, m *MessageContext) error {
+	if m == nil || m.Message == nil || len(m.Message.Raw) == 0 {
+		return nil
+	}
+	reader, err := mail.ReadMessage(bytes.NewReader(m.Message.Raw))
+	if err != nil {
+		f.logf("bounce: parse failed: %v", err)
+		return nil
+	}
+	reports := parseBounce(reader)
+	if len(reports) == 0 {
+		return nil
+	}
+	if m.Annotations == nil {
+		m.Annotations = make(map[string]any)
+	}
+	m.Annotations[AnnotationNoAutoResponse] = true
+	m.Annotations[AnnotationBounceType] = reports[0].BounceType
+	m.Annotations[AnnotationBounceRecipient] = reports[0].Email
+	for _, report := range reports {
+		f.logf("bounce: message %s: %s bounce for %s (%s)", m.Message.UID, report.BounceType, report.Email, report.Status)
+		if f.recorder == nil {
+			continue
+		}
+		if _, err := f.recorder.RecordBounce(ctx, report); err != nil {
+			f.logf("bounce: failed to record bounce for %s: %v", report.Email, err)
+		}
+	}
+	return nil
+}
+
+// parseBounce returns the failed recipients of a delivery failure report:
+// an RFC 3464 delivery status notification, or a non-standard bounce from a
+// mailer daemon naming the recipients in X-Failed-Recipients.
+func parseBounce(msg *mail.Message) []models.EmailBounce {
+	body, err := io.ReadAll(io.LimitReader(msg.Body, 1<<20))
+	if err != nil {
+		return nil
+	}
+	if reports := deliveryStatusReports(textproto.MIMEHeader(msg.Header), body, 0); len(reports) > 0 {
+		return reports
+	}
+
+	failed := msg.Header.Get("X-Failed-Recipients")
+	if failed == "" && !isMailerDaemon(msg.Header) {
+		return nil
+	}
+	var recipients []string
+	for _, addr := range strings.Split(failed, ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			recipients = append(recipients, addr)
+		}
+	}
+	if len(recipients) == 0 {
+		return nil
+	}
+	status := bounceStatusPattern.FindString(string(body))
+	bounceType := classifyBounce("failed", status)
+	if bounceType == "" {
+		return nil
+	}
+	reports := make([]models.EmailBounce, 0, len(recipients))
+	for _, addr := range recipients {
+		reports = append(reports, models.EmailBounce{Email: strings.ToLower(addr), BounceType: bounceType, Status: status})
+	}
+	return reports
+}
+
+// isMailerDaemon reports whether a message comes from a mailer daemon.
+func isMailerDaemon(header mail.Header) bool {
+	if strings.TrimSpace(header.Get("Return-Path")) == "<>" {
+		return true
+	}
+	from, err := header.AddressList("From")
+	if err != nil || len(from) == 0 {
+		return false
+	}
+	local := strings.ToLower(from[0].Address)
+	if i := strings.Index(local, "@"); i >= 0 {
+		local = local[:i]
+	}
+	return local == "mailer-daemon" || local == "postmaster"
+}
+
+// deliveryStatusReports finds a message/delivery-status part in a
+// multipart message and returns a report per failed or delayed recipient.
+func deliveryStatusReports(header textproto.MIMEHeader, body []byte, depth int) []models.EmailBounce {
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		return nil
+	}
+	if mediaType == "message/delivery-status" {
+		return parseDeliveryStatus(body)
+	}
+	if !strings.HasPrefix(mediaType, "multipart/") || params["boundary"] == "" || depth >= maxBounceDepth {
+		return nil
+	}
+	mr := multipart.NewReader(bytes.NewReader(body), params["boundary"])
+	for {
+		part, err := mr.NextRawPart()
+		if err != nil {
+			return nil
+		}
+		data, err := io.ReadAll(io.LimitReader(part, 1<<20))
+		if err != nil {
+			return nil
+		}
+		if reports := deliveryStatusReports(part.Header, data, depth+1); len(reports) > 0 {
+			return reports
+		}
+	}
+}
+
+// parseDeliveryStatus parses the per-recipient fields of an RFC 3464
+// delivery status body.
+func parseDeliveryStatus(body []byte) []models.EmailBounce {
+	text := strings.ReplaceAll(string(body), "\r\n", "\n")
+	var reports []models.EmailBounce
+	for _, block := range strings.Split(text, "\n\n") {
+		if strings.TrimSpace(block) == "" {
+			continue
+		}
+		fields, err := textproto.NewReader(bufio.NewReader(strings.NewReader(strings.TrimLeft(block, "\n") + "\n\n"))).ReadMIMEHeader()
+		if err != nil && len(fields) == 0 {
+			continue
+		}
+		recipient := deliveryStatusAddress(fields.Get("Final-Recipient"))
+		if recipient == "" {
+			recipient = deliveryStatusAddress(fields.Get("Original-Recipient"))
+		}
+		if recipient == "" {
+			continue
+		}
+		status := strings.TrimSpace(fields.Get("Status"))
+		if match := bounceStatusPattern.FindString(status); match != "" {
+			status = match
+		}
+		bounceType := classifyBounce(fields.Get("Action"), status)
+		if bounceType == "" {
+			continue
+		}
+		reports = append(reports, models.EmailBounce{
+			Email:      recipient,
+			BounceType: bounceType,
+			Status:     status,
+			Diagnostic: deliveryStatusDiagnostic(fields.Get("Diagnostic-Code")),
+		})
+	}
+	return reports
+}
+
+// classifyBounce returns the bounce type of a delivery status action and
+// status code: 5.x.x is a hard bounce and 4.x.x a soft one; without a
+// status, failed is hard and delayed soft. Successful deliveries return "".
+func classifyBounce(action, status string) string {
+	action = strings.ToLower(strings.TrimSpace(action))
+	switch action {
+	case "delivered", "relayed", "expanded":
+		return ""
+	}
+	switch {
+	case strings.HasPrefix(status, "5."):
+		return models.BounceTypeHard
+	case strings.HasPrefix(status, "4."):
+		return models.BounceTypeSoft
+	case strings.HasPrefix(status, "2."):
+		return ""
+	}
+	switch action {
+	case "failed":
+		return models.BounceTypeHard
+	case "delayed":
+		return models.BounceTypeSoft
+	}
+	return ""
+}
+
+// deliveryStatusAddress returns the address of an address-type; address
+// field such as "rfc822; jane@example.com".
+func deliveryStatusAddress(value string) string {
+	if i := strings.Index(value, ";"); i >= 0 {
+		value = value[i+1:]
+	}
+	value = strings.Trim(strings.TrimSpace(value), "<>")
+	if !strings.Contains(value, "@") {
+		return ""
+	}
+	return strings.ToLower(value)
+}
+
+// deliveryStatusDiagnostic returns the text of a diagnostic-type;
+// diagnostic field such as "smtp; 550 5.1.1 User unknown".
+func deliveryStatusDiagnostic(value string) string {
+	if i := strings.Index(value, ";"); i >= 0 {
+		value = value[i+1:]
+	}
+	return strings.Join(strings.Fields(value), " ")
+}
+
+func (f *BounceFilter) logf(format string, args ...any) {
+	if f == nil || f.logger == nil {
+		return
+	}
+	f.logger.Printf(format, args...)
+}
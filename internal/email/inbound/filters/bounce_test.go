@@ -0,0 +1,111 @@
+package filters
+
+import (
+	"context"
+	"testing"
+
+	"github.com/goatkit/goatflow/internal/email/inbound/connector"
+	"github.com/goatkit/goatflow/internal/models"
+)
+
+type stubBounceRecorder struct {
+	reports []models.EmailBounce
+}
+
+func (s *stubBounceRecorder) RecordBounce(_ context.Context, report models.EmailBounce) (*models.EmailBounce, error) {
+	s.reports = append(s.reports, report)
+	return &report, nil
+}
+
+const dsnBounce = "From: Mail Delivery System <MAILER-DAEMON@mx.example.com>\r\n" +
+	"To: support@goatflow.local\r\n" +
+	"Subject: Undelivered Mail Returned to Sender\r\n" +
+	"MIME-Version: 1.0\r\n" +
+	"Content-Type: multipart/report; report-type=delivery-status; boundary=\"BOUND\"\r\n" +
+	"\r\n" +
+	"--BOUND\r\n" +
+	"Content-Type: text/plain\r\n" +
+	"\r\n" +
+	"Your message could not be delivered.\r\n" +
+	"--BOUND\r\n" +
+	"Content-Type: message/delivery-status\r\n" +
+	"\r\n" +
+	"Reporting-MTA: dns; mx.example.com\r\n" +
+	"\r\n" +
+	"Final-Recipient: rfc822; Jane@Example.com\r\n" +
+	"Action: failed\r\n" +
+	"Status: 5.1.1\r\n" +
+	"Diagnostic-Code: smtp; 550 5.1.1 <jane@example.com>:\r\n" +
+	"    Recipient address rejected: User unknown\r\n" +
+	"\r\n" +
+	"Final-Recipient: rfc822; bob@example.com\r\n" +
+	"Action: delayed\r\n" +
+	"Status: 4.2.2\r\n" +
+	"\r\n" +
+	"Final-Recipient: rfc822; carol@example.com\r\n" +
+	"Action: delivered\r\n" +
+	"Status: 2.0.0\r\n" +
+	"--BOUND--\r\n"
+
+func TestBounceFilterRecordsDeliveryStatusReports(t *testing.T) {
+	recorder := &stubBounceRecorder{}
+	filter := NewBounceFilter(recorder, nil)
+	ctx := &MessageContext{Message: &connector.FetchedMessage{Raw: []byte(dsnBounce)}}
+	if err := filter.Apply(context.Background(), ctx); err != nil {
+		t.Fatalf("Apply returned error: %v", err)
+	}
+	if len(recorder.reports) != 2 {
+		t.Fatalf("expected 2 bounces, got %+v", recorder.reports)
+	}
+	hard, soft := recorder.reports[0], recorder.reports[1]
+	if hard.Email != "jane@example.com" || hard.BounceType != models.BounceTypeHard || hard.Status != "5.1.1" {
+		t.Fatalf("unexpected hard bounce %+v", hard)
+	}
+	if hard.Diagnostic != "550 5.1.1 <jane@example.com>: Recipient address rejected: User unknown" {
+		t.Fatalf("unexpected diagnostic %q", hard.Diagnostic)
+	}
+	if soft.Email != "bob@example.com" || soft.BounceType != models.BounceTypeSoft {
+		t.Fatalf("unexpected soft bounce %+v", soft)
+	}
+	if ctx.Annotations[AnnotationNoAutoResponse] != true {
+		t.Fatalf("expected auto responses to be suppressed")
+	}
+	if ctx.Annotations[AnnotationBounceType] != models.BounceTypeHard || ctx.Annotations[AnnotationBounceRecipient] != "jane@example.com" {
+		t.Fatalf("unexpected bounce annotations %v", ctx.Annotations)
+	}
+}
+
+func TestBounceFilterRecordsFailedRecipientsHeader(t *testing.T) {
+	recorder := &stubBounceRecorder{}
+	filter := NewBounceFilter(recorder, nil)
+	raw := "From: Mail Delivery System <Mailer-Daemon@mx.example.com>\r\nX-Failed-Recipients: dave@example.com\r\n" +
+		"Subject: Mail delivery failed\r\n\r\nSMTP error from remote mail server: 452 4.2.2 Mailbox full\r\n"
+	ctx := &MessageContext{Message: &connector.FetchedMessage{Raw: []byte(raw)}}
+	if err := filter.Apply(context.Background(), ctx); err != nil {
+		t.Fatalf("Apply returned error: %v", err)
+	}
+	if len(recorder.reports) != 1 || recorder.reports[0].Email != "dave@example.com" ||
+		recorder.reports[0].BounceType != models.BounceTypeSoft || recorder.reports[0].Status != "4.2.2" {
+		t.Fatalf("unexpected bounces %+v", recorder.reports)
+	}
+}
+
+func TestBounceFilterIgnoresRegularMail(t *testing.T) {
+	recorder := &stubBounceRecorder{}
+	filter := NewBounceFilter(recorder, nil)
+	for _, raw := range []string{
+		"From: jane@example.com\r\nSubject: Printer\r\n\r\nThe printer is jammed (error 5.1.1).\r\n",
+		"From: MAILER-DAEMON@mx.example.com\r\nSubject: Warning\r\n\r\nStill trying.\r\n",
+	} {
+		ctx := &MessageContext{Message: &connector.FetchedMessage{Raw: []byte(raw)}}
+		if err := filter.Apply(context.Background(), ctx); err != nil {
+			t.Fatalf("Apply returned error: %v", err)
+		}
+		if len(ctx.Annotations) != 0 {
+			t.Fatalf("expected no annotations, got %v", ctx.Annotations)
+		}
+	}
+	if len(recorder.reports) != 0 {
+		t.Fatalf("expected no bounces, got %+v", recorder.reports)
+	}
+}
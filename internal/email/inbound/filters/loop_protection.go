@@ -0,0 +1,104 @@
+package filters
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"net/mail"
+	"strings"
+)
+
+// LoopChecker tells the loop protection filter about the system's own
+// addresses and the automatic emails already sent to an address.
+type LoopChecker interface {
+	IsSystemAddress(ctx context.Context, address string) (bool, error)
+	SenderExceeded(ctx context.Context, address string) (bool, error)
+}
+
+// LoopProtectionFilter suppresses auto responses to messages that would
+// start or continue a mail loop: messages carrying this system's loop
+// headers, an X-Loop naming one of its addresses, or senders that already
+// got the daily maximum of automatic emails (OTRS PostmasterMaxEmails).
+type LoopProtectionFilter struct {
+	checker LoopChecker
+	logger  *log.Logger
+}
+
+// NewLoopProtectionFilter creates a loop protection filter.
+func NewLoopProtectionFilter(checker LoopChecker, logger *log.Logger) *LoopProtectionFilter {
+	return &LoopProtectionFilter{checker: checker, logger: logger}
+}
+
+// ID returns the filter identifier.
+func (f *LoopProtectionFilter) ID() string { return "loop_protection" }
+
+var loopHeaders = canonicalHeaderList("X-GoatFlow-Loop", "X-OTRS-Loop")
+
+// Apply annotates messages that take part in a mail loop.
+func (f *LoopProtectionFilter) Apply(ctx context.Context, m *MessageContext) error {
+	if m == nil || m.Message == nil || len(m.Message.Raw) == 0 {
+		return nil
+	}
+	reader, err := mail.ReadMessage(bytes.NewReader(m.Message.Raw))
+	if err != nil {
+		f.logf("loop_protection: parse failed: %v", err)
+		return nil
+	}
+	reason := f.loopReason(ctx, reader.Header)
+	if reason == "" {
+		return nil
+	}
+	if m.Annotations == nil {
+		m.Annotations = make(map[string]any)
+	}
+	m.Annotations[AnnotationNoAutoResponse] = true
+	m.Annotations[AnnotationLoopDetected] = reason
+	f.logf("loop_protection: message %s: %s", m.Message.UID, reason)
+	return nil
+}
+
+// loopReason returns why a message is part of a loop, or "".
+func (f *LoopProtectionFilter) loopReason(ctx context.Context, header mail.Header) string {
+	switch strings.ToLower(strings.TrimSpace(firstHeaderValue(header, loopHeaders))) {
+	case "", "0", "false", "no":
+	default:
+		return "loop header"
+	}
+	if f.checker == nil {
+		return ""
+	}
+	for _, value := range header["X-Loop"] {
+		addr := strings.TrimSpace(value)
+		if parsed, err := mail.ParseAddress(addr); err == nil {
+			addr = parsed.Address
+		}
+		own, err := f.checker.IsSystemAddress(ctx, addr)
+		if err != nil {
+			f.logf("loop_protection: address lookup failed: %v", err)
+			return ""
+		}
+		if own {
+			return "X-Loop " + addr
+		}
+	}
+	from, err := header.AddressList("From")
+	if err != nil || len(from) == 0 {
+		return ""
+	}
+	exceeded, err := f.checker.SenderExceeded(ctx, from[0].Address)
+	if err != nil {
+		f.logf("loop_protection: rate lookup failed: %v", err)
+		return ""
+	}
+	if exceeded {
+		return "sender " + strings.ToLower(from[0].Address) + " reached the daily maximum of automatic emails"
+	}
+	return ""
+}
+
+func (f *LoopProtectionFilter) logf(format string, args ...any) {
+	if f == nil || f.logger == nil {
+		return
+	}
+	f.logger.Printf(format, args...)
+}
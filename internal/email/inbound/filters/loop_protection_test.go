@@ -0,0 +1,63 @@
+package filters
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/goatkit/goatflow/internal/email/inbound/connector"
+)
+
+type stubLoopChecker struct {
+	own      map[string]bool
+	exceeded map[string]bool
+}
+
+func (s stubLoopChecker) IsSystemAddress(_ context.Context, address string) (bool, error) {
+	return s.own[strings.ToLower(address)], nil
+}
+
+func (s stubLoopChecker) SenderExceeded(_ context.Context, address string) (bool, error) {
+	return s.exceeded[strings.ToLower(address)], nil
+}
+
+func TestLoopProtectionFilterDetectsLoops(t *testing.T) {
+	filter := NewLoopProtectionFilter(stubLoopChecker{
+		own:      map[string]bool{"support@goatflow.local": true},
+		exceeded: map[string]bool{"busy@example.com": true},
+	}, nil)
+	cases := map[string]string{
+		"X-OTRS-Loop: yes\r\nFrom: jane@example.com\r\n\r\nbody":                 "loop header",
+		"X-GoatFlow-Loop: yes\r\nFrom: jane@example.com\r\n\r\nbody":             "loop header",
+		"X-Loop: <Support@goatflow.local>\r\nFrom: jane@example.com\r\n\r\nbody": "X-Loop Support@goatflow.local",
+		"From: Busy <Busy@example.com>\r\n\r\nbody":                              "sender busy@example.com reached the daily maximum of automatic emails",
+	}
+	for raw, want := range cases {
+		ctx := &MessageContext{Message: &connector.FetchedMessage{Raw: []byte(raw)}}
+		if err := filter.Apply(context.Background(), ctx); err != nil {
+			t.Fatalf("Apply returned error: %v", err)
+		}
+		if ctx.Annotations[AnnotationNoAutoResponse] != true {
+			t.Fatalf("expected auto responses to be suppressed for %q", raw)
+		}
+		if got := ctx.Annotations[AnnotationLoopDetected]; got != want {
+			t.Fatalf("expected reason %q, got %v", want, got)
+		}
+	}
+}
+
+func TestLoopProtectionFilterPassesRegularMail(t *testing.T) {
+	filter := NewLoopProtectionFilter(stubLoopChecker{}, nil)
+	for _, raw := range []string{
+		"From: jane@example.com\r\n\r\nbody",
+		"X-OTRS-Loop: no\r\nX-Loop: list@example.com\r\nFrom: jane@example.com\r\n\r\nbody",
+	} {
+		ctx := &MessageContext{Message: &connector.FetchedMessage{Raw: []byte(raw)}}
+		if err := filter.Apply(context.Background(), ctx); err != nil {
+			t.Fatalf("Apply returned error: %v", err)
+		}
+		if len(ctx.Annotations) != 0 {
+			t.Fatalf("expected no annotations for %q, got %v", raw, ctx.Annotations)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package postmaster
+
+import (
+	"bytes"
+	"context"
+	stdmail "net/mail"
+	"strings"
+
+	"github.com/goatkit/goatflow/internal/email/inbound/connector"
+	"github.com/goatkit/goatflow/internal/email/inbound/filters"
+	"github.com/goatkit/goatflow/internal/service"
+)
+
+// autoResponder sends the queue's auto response for an inbound message.
+type autoResponder interface {
+	Respond(ctx context.Context, in service.AutoResponseInput) (bool, error)
+}
+
+// WithTicketProcessorAutoResponder answers new tickets, follow-ups and
+// rejected follow-ups with the auto responses assigned to their queue.
+func WithTicketProcessorAutoResponder(responder autoResponder) TicketProcessorOption {
+	return func(tp *TicketProcessor) {
+		if responder != nil {
+			tp.autoResponder = responder
+		}
+	}
+}
+
+// autoRespond sends the auto response of typeID for a stored message.
+// Failures are logged: the message itself has been processed.
+func (tp *TicketProcessor) autoRespond(ctx context.Context, msg *connector.FetchedMessage, meta *filters.MessageContext, env *envelope, typeID, ticketID, queueID int) {
+	if tp.autoResponder == nil || msg == nil || env == nil {
+		return
+	}
+	if annotationBool(meta, filters.AnnotationNoAutoResponse) {
+		tp.logf("postmaster: auto response suppressed for message %s", msg.UID)
+		return
+	}
+	to, automated := autoResponseHeaders(msg.Raw)
+	if to == "" {
+		to = env.CustomerUserID
+	}
+	in := service.AutoResponseInput{
+		TypeID:    typeID,
+		TicketID:  ticketID,
+		QueueID:   queueID,
+		To:        to,
+		Subject:   env.Subject,
+		Automated: automated,
+	}
+	if env.MessageID != "" {
+		in.MessageID = "<" + env.MessageID + ">"
+	}
+	if _, err := tp.autoResponder.Respond(ctx, in); err != nil {
+		tp.logf("postmaster: auto response for ticket %d failed: %v", ticketID, err)
+	}
+}
+
+// autoResponseHeaders returns the Reply-To address of a raw message and
+// whether the message was generated automatically (RFC 3834 Auto-Submitted,
+// bulk or list Precedence, or an auto response of this system).
+func autoResponseHeaders(raw []byte) (string, bool) {
+	msg, err := stdmail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return "", false
+	}
+	header := msg.Header
+	var replyTo string
+	if list, err := header.AddressList("Reply-To"); err == nil && len(list) > 0 {
+		replyTo = strings.TrimSpace(list[0].Address)
+	}
+	automated := header.Get(service.AutoResponseLoopHeader) != ""
+	if submitted := strings.ToLower(strings.TrimSpace(header.Get("Auto-Submitted"))); submitted != "" && submitted != "no" {
+		automated = true
+	}
+	switch strings.ToLower(strings.TrimSpace(header.Get("Precedence"))) {
+	case "bulk", "junk", "list":
+		automated = true
+	}
+	return replyTo, automated
+}
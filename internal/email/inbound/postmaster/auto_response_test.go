@@ -0,0 +1,117 @@
+package postmaster
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/email/inbound/connector"
+	"github.com/goatkit/goatflow/internal/email/inbound/filters"
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/service"
+)
+
+type stubTickets struct{ created []service.CreateTicketInput }
+
+func (s *stubTickets) Create(_ context.Context, in service.CreateTicketInput) (*models.Ticket, error) {
+	s.created = append(s.created, in)
+	return &models.Ticket{ID: 100 + len(s.created), QueueID: in.QueueID}, nil
+}
+
+type stubTicketFinder struct{ ticket *models.Ticket }
+
+func (s stubTicketFinder) GetByTicketNumber(string) (*models.Ticket, error) { return s.ticket, nil }
+
+type stubQueueFinder struct{ followUpID int }
+
+func (s stubQueueFinder) GetByID(id uint) (*models.Queue, error) {
+	return &models.Queue{ID: id, FollowUpID: s.followUpID}, nil
+}
+
+type stubArticles struct{}
+
+func (stubArticles) Create(a *models.Article) error {
+	a.ID = 7
+	return nil
+}
+
+type stubResponder struct{ sent []service.AutoResponseInput }
+
+func (s *stubResponder) Respond(_ context.Context, in service.AutoResponseInput) (bool, error) {
+	s.sent = append(s.sent, in)
+	return true, nil
+}
+
+func TestTicketProcessorAutoResponses(t *testing.T) {
+	raw := []byte("From: Jane <jane@example.com>\r\nReply-To: help@example.com\r\nSubject: Printer\r\n" +
+		"Message-ID: <m1@example.com>\r\n\r\nIt is jammed.\r\n")
+	followUp := func() *filters.MessageContext {
+		return &filters.MessageContext{Annotations: map[string]any{filters.AnnotationFollowUpTicketNumber: "1001"}}
+	}
+	newProcessor := func(followUpID int) (*TicketProcessor, *stubTickets, *stubResponder) {
+		tickets, responder := &stubTickets{}, &stubResponder{}
+		tp := NewTicketProcessor(tickets,
+			WithTicketProcessorFallbackQueue(3),
+			WithTicketProcessorTicketFinder(stubTicketFinder{ticket: &models.Ticket{ID: 1, QueueID: 2}}),
+			WithTicketProcessorQueueFinder(stubQueueFinder{followUpID: followUpID}),
+			WithTicketProcessorArticleStore(stubArticles{}),
+			WithTicketProcessorAutoResponder(responder))
+		return tp, tickets, responder
+	}
+	ctx := context.Background()
+
+	tp, _, responder := newProcessor(1)
+	res, err := tp.Process(ctx, &connector.FetchedMessage{Raw: raw}, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "new_ticket", res.Action)
+	require.Len(t, responder.sent, 1)
+	assert.Equal(t, service.AutoResponseInput{TypeID: models.AutoResponseTypeReply, TicketID: 101, QueueID: 3,
+		To: "help@example.com", Subject: "Printer", MessageID: "<m1@example.com>"}, responder.sent[0])
+
+	res, err = tp.Process(ctx, &connector.FetchedMessage{Raw: raw}, followUp())
+	require.NoError(t, err)
+	assert.Equal(t, "follow_up", res.Action)
+	assert.Equal(t, models.AutoResponseTypeFollowUp, responder.sent[1].TypeID)
+	assert.Equal(t, 2, responder.sent[1].QueueID, "the ticket's queue answers follow-ups")
+
+	tp, tickets, responder := newProcessor(2)
+	res, err = tp.Process(ctx, &connector.FetchedMessage{Raw: raw}, followUp())
+	require.NoError(t, err)
+	assert.Equal(t, "rejected", res.Action)
+	assert.Equal(t, 1, res.TicketID)
+	assert.Empty(t, tickets.created)
+	assert.Equal(t, models.AutoResponseTypeReject, responder.sent[0].TypeID)
+
+	tp, tickets, responder = newProcessor(3)
+	res, err = tp.Process(ctx, &connector.FetchedMessage{Raw: raw}, followUp())
+	require.NoError(t, err)
+	assert.Equal(t, "new_ticket", res.Action)
+	assert.Len(t, tickets.created, 1)
+	assert.Equal(t, models.AutoResponseTypeReplyNewTicket, responder.sent[0].TypeID)
+
+	suppressed := followUp()
+	suppressed.Annotations[filters.AnnotationNoAutoResponse] = true
+	_, err = tp.Process(ctx, &connector.FetchedMessage{Raw: raw}, suppressed)
+	require.NoError(t, err)
+	assert.Len(t, responder.sent, 1)
+}
+
+func TestAutoResponseHeaders(t *testing.T) {
+	replyTo, automated := autoResponseHeaders([]byte("From: a@example.com\r\nSubject: hi\r\n\r\nbody"))
+	assert.Empty(t, replyTo)
+	assert.False(t, automated)
+
+	for _, header := range []string{
+		"Auto-Submitted: auto-replied",
+		"Precedence: bulk",
+		"Precedence: list",
+		"X-GoatFlow-Loop: yes",
+	} {
+		_, automated := autoResponseHeaders([]byte("From: a@example.com\r\n" + header + "\r\n\r\nbody"))
+		assert.True(t, automated, header)
+	}
+	_, automated = autoResponseHeaders([]byte("From: a@example.com\r\nAuto-Submitted: no\r\n\r\nbody"))
+	assert.False(t, automated)
+}
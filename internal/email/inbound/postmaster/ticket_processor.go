@@ -69,6 +69,9 @@ type TicketProcessor struct {
 	messageLookup   messageTicketLookup
 	db              *sql.DB
 	attachmentLimit int64
+	scanner         attachmentScanner
+	autoResponder   autoResponder
+	visibleText     visibleTextStore
 }
 
 const (
@@ -78,6 +81,12 @@ const (
 	defaultAttachmentLimit = 25 * 1024 * 1024
 )
 
+// attachmentScanner checks attachments before they are stored; it returns
+// an error for attachments that must not be.
+type attachmentScanner interface {
+	Check(ctx context.Context, in service.AttachmentScanInput) (*models.AttachmentScan, error)
+}
+
 type articleFinder interface {
 	GetLatestCustomerArticleForTicket(ticketID uint) (*models.Article, error)
 }
@@ -221,6 +230,16 @@ func WithTicketProcessorDatabase(db *sql.DB) TicketProcessorOption {
 	}
 }
 
+// WithTicketProcessorScanner scans attachments before they are stored,
+// skipping those the scanner quarantines.
+func WithTicketProcessorScanner(scanner attachmentScanner) TicketProcessorOption {
+	return func(tp *TicketProcessor) {
+		if scanner != nil {
+			tp.scanner = scanner
+		}
+	}
+}
+
 // WithTicketProcessorAttachmentLimit overrides the maximum attachment bytes buffered in memory.
 func WithTicketProcessorAttachmentLimit(limit int64) TicketProcessorOption {
 	return func(tp *TicketProcessor) {
@@ -258,9 +277,15 @@ func (tp *TicketProcessor) Process(ctx context.Context, msg *connector.FetchedMe
 		title = tp.defaultSubject(msg)
 	}
 	env.Subject = title
-	if res, handled, err := tp.tryFollowUp(ctx, msg, meta, &env); handled {
+	res, handled, err := tp.tryFollowUp(ctx, msg, meta, &env)
+	if handled {
 		return res, err
 	}
+	// A follow-up the queue turns into a new ticket gets its own response.
+	responseType := models.AutoResponseTypeReply
+	if res.Action == "follow_up_new_ticket" {
+		responseType = models.AutoResponseTypeReplyNewTicket
+	}
 
 	mimeType := tp.resolveMimeType(env.ContentType)
 	charset := tp.resolveCharset(env.Charset)
@@ -294,7 +319,9 @@ func (tp *TicketProcessor) Process(ctx context.Context, msg *connector.FetchedMe
 	}
 	if articleID := tp.resolveArticleID(ticket.ID); articleID > 0 {
 		tp.storeAttachments(ctx, ticket.ID, articleID, env.Attachments)
+		tp.storeVisibleText(ctx, articleID, &env)
 	}
+	tp.autoRespond(ctx, msg, meta, &env, responseType, ticket.ID, queueID)
 
 	return Result{TicketID: ticket.ID, Action: "new_ticket"}, nil
 }
@@ -735,6 +762,20 @@ func (tp *TicketProcessor) storeAttachment(ctx context.Context, ticketID, articl
 	if ctx == nil {
 		ctx = context.Background()
 	}
+	if tp.scanner != nil {
+		if _, err := tp.scanner.Check(ctx, service.AttachmentScanInput{
+			TicketID:    ticketID,
+			ArticleID:   articleID,
+			Filename:    att.filename,
+			ContentType: att.contentType,
+			Content:     att.data,
+			Source:      models.AttachmentSourceEmail,
+			UserID:      tp.systemUserID,
+		}); err != nil {
+			tp.logf("postmaster: attachment %s not stored: %v", att.filename, err)
+			return
+		}
+	}
 	ctx = service.WithArticleID(ctx, articleID)
 	ctx = service.WithUserID(ctx, tp.systemUserID)
 	file := newMemoryFile(att.data)
@@ -767,9 +808,17 @@ func (tp *TicketProcessor) tryFollowUp(ctx context.Context, msg *connector.Fetch
 	if ticket == nil {
 		return Result{}, false, nil
 	}
-	if !tp.followUpAllowed(ticket.QueueID) {
+	action, responseType := "follow_up", models.AutoResponseTypeFollowUp
+	switch tp.followUpPolicy(ticket.QueueID) {
+	case followUpPossible:
+	case followUpReject:
+		// Like OTRS, a rejected follow-up is still stored on the ticket;
+		// the customer is told to open a new one.
 		tp.logf("postmaster: queue %d rejects follow-up for ticket %d", ticket.QueueID, ticket.ID)
-		return Result{}, false, nil
+		action, responseType = "rejected", models.AutoResponseTypeReject
+	default:
+		tp.logf("postmaster: queue %d opens a new ticket for follow-up to ticket %d", ticket.QueueID, ticket.ID)
+		return Result{Action: "follow_up_new_ticket"}, false, nil
 	}
 	article := tp.buildFollowUpArticle(ticket.ID, env, msg)
 	if article == nil {
@@ -779,29 +828,41 @@ func (tp *TicketProcessor) tryFollowUp(ctx context.Context, msg *connector.Fetch
 		return Result{}, true, err
 	}
 	tp.storeAttachments(ctx, ticket.ID, article.ID, env.Attachments)
+	tp.storeVisibleText(ctx, article.ID, env)
 	tp.logf("postmaster: appended follow-up to ticket %d", ticket.ID)
-	return Result{TicketID: ticket.ID, ArticleID: article.ID, Action: "follow_up"}, true, nil
+	tp.autoRespond(ctx, msg, meta, env, responseType, ticket.ID, ticket.QueueID)
+	return Result{TicketID: ticket.ID, ArticleID: article.ID, Action: action}, true, nil
 }
 
-func (tp *TicketProcessor) followUpAllowed(queueID int) bool {
+// Queue follow-up policies (follow_up_possible).
+const (
+	followUpPossible  = 1
+	followUpReject    = 2
+	followUpNewTicket = 3
+)
+
+func (tp *TicketProcessor) followUpPolicy(queueID int) int {
 	if queueID <= 0 {
-		return false
+		return followUpNewTicket
 	}
 	if tp.queueFinder == nil {
-		return true
+		return followUpPossible
 	}
 	queue, err := tp.queueFinder.GetByID(uint(queueID))
 	if err != nil {
 		tp.logf("postmaster: queue lookup failed for %d: %v", queueID, err)
-		return true
+		return followUpPossible
 	}
 	if queue == nil {
-		return false
+		return followUpNewTicket
 	}
-	if queue.FollowUpID == 0 || queue.FollowUpID == 1 {
-		return true
+	switch queue.FollowUpID {
+	case 0, followUpPossible:
+		return followUpPossible
+	case followUpReject:
+		return followUpReject
 	}
-	return false
+	return followUpNewTicket
 }
 
 func (tp *TicketProcessor) resolveFollowUpTicket(ctx context.Context, meta *filters.MessageContext, env *envelope) *models.Ticket {
@@ -0,0 +1,47 @@
+package postmaster
+
+import (
+	"context"
+	"time"
+
+	"github.com/goatkit/goatflow/internal/email/quote"
+	"github.com/goatkit/goatflow/internal/models"
+)
+
+// visibleTextStore keeps the body of an article without quoted messages
+// and signature.
+type visibleTextStore interface {
+	Save(ctx context.Context, vt *models.ArticleVisibleText) error
+}
+
+// WithTicketProcessorVisibleText stores, for messages that quote earlier
+// messages or end in a signature, the part the sender wrote. It is
+// searched and shown instead of the full body, which is kept.
+func WithTicketProcessorVisibleText(store visibleTextStore) TicketProcessorOption {
+	return func(tp *TicketProcessor) {
+		if store != nil {
+			tp.visibleText = store
+		}
+	}
+}
+
+// storeVisibleText splits the body of a stored message. Failures are
+// logged: the article itself has been stored.
+func (tp *TicketProcessor) storeVisibleText(ctx context.Context, articleID int, env *envelope) {
+	if tp.visibleText == nil || env == nil || articleID <= 0 {
+		return
+	}
+	res := quote.Split(env.Body, tp.resolveMimeType(env.ContentType))
+	if !res.Collapsed() {
+		return
+	}
+	if err := tp.visibleText.Save(ctx, &models.ArticleVisibleText{
+		ArticleID:    int64(articleID),
+		VisibleText:  res.Visible,
+		HasQuote:     res.HasQuote,
+		HasSignature: res.HasSignature,
+		CreateTime:   time.Now(),
+	}); err != nil {
+		tp.logf("postmaster: visible text for article %d not stored: %v", articleID, err)
+	}
+}
@@ -0,0 +1,46 @@
+package postmaster
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/email/inbound/connector"
+	"github.com/goatkit/goatflow/internal/email/inbound/filters"
+	"github.com/goatkit/goatflow/internal/models"
+)
+
+type stubVisibleText struct{ saved []*models.ArticleVisibleText }
+
+func (s *stubVisibleText) Save(_ context.Context, vt *models.ArticleVisibleText) error {
+	s.saved = append(s.saved, vt)
+	return nil
+}
+
+func TestTicketProcessorVisibleText(t *testing.T) {
+	store := &stubVisibleText{}
+	tp := NewTicketProcessor(&stubTickets{},
+		WithTicketProcessorFallbackQueue(3),
+		WithTicketProcessorTicketFinder(stubTicketFinder{ticket: &models.Ticket{ID: 1, QueueID: 2}}),
+		WithTicketProcessorQueueFinder(stubQueueFinder{followUpID: 1}),
+		WithTicketProcessorArticleStore(stubArticles{}),
+		WithTicketProcessorVisibleText(store))
+	followUp := &filters.MessageContext{Annotations: map[string]any{filters.AnnotationFollowUpTicketNumber: "1001"}}
+	ctx := context.Background()
+
+	reply := []byte("From: Jane <jane@example.com>\r\nSubject: Re: Printer\r\n\r\n" +
+		"Still jammed.\r\n\r\nOn Mon, 3 Feb 2025, Support <support@example.com> wrote:\r\n> Please restart it.\r\n")
+	_, err := tp.Process(ctx, &connector.FetchedMessage{Raw: reply}, followUp)
+	require.NoError(t, err)
+	require.Len(t, store.saved, 1)
+	assert.Equal(t, int64(7), store.saved[0].ArticleID)
+	assert.Equal(t, "Still jammed.", store.saved[0].VisibleText)
+	assert.True(t, store.saved[0].HasQuote)
+
+	plain := []byte("From: Jane <jane@example.com>\r\nSubject: Re: Printer\r\n\r\nStill jammed.\r\n")
+	_, err = tp.Process(ctx, &connector.FetchedMessage{Raw: plain}, followUp)
+	require.NoError(t, err)
+	assert.Len(t, store.saved, 1, "messages without quote or signature are not stored twice")
+}
@@ -0,0 +1,184 @@
+package quote
+
+import (
+	"bytes"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// splitHTML removes the first quoted message, with everything after it,
+// and signatures above it from an HTML body.
+func splitHTML(body string) Result {
+	res := Result{Visible: body}
+	doc, err := html.Parse(strings.NewReader(body))
+	if err != nil {
+		return res
+	}
+
+	var quote *html.Node
+	var signatures []*html.Node
+	var walk func(*html.Node) bool
+	walk = func(n *html.Node) bool {
+		if n.Type == html.ElementNode {
+			if isHTMLQuote(n) {
+				quote = n
+				return true
+			}
+			if isHTMLSignature(n) {
+				signatures = append(signatures, n)
+				return false
+			}
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if walk(c) {
+				return true
+			}
+		}
+		return false
+	}
+	walk(doc)
+	if quote == nil && len(signatures) == 0 {
+		return res
+	}
+
+	for _, sig := range signatures {
+		sig.Parent.RemoveChild(sig)
+	}
+	if quote != nil {
+		// Apple Mail puts the "On ... wrote:" line in the element before
+		// the quote
+		if prev := previousElement(quote); prev != nil && attributionPattern.MatchString(strings.TrimSpace(textContent(prev))) {
+			quote = prev
+		}
+		cutFrom(quote)
+	}
+	if strings.TrimSpace(textContent(doc)) == "" {
+		return res
+	}
+
+	var buf bytes.Buffer
+	if err := render(&buf, doc, strings.Contains(strings.ToLower(body), "<html")); err != nil {
+		return res
+	}
+	res.Visible = buf.String()
+	res.HasQuote = quote != nil
+	res.HasSignature = len(signatures) > 0
+	return res
+}
+
+// isHTMLQuote tells whether an element starts a quoted message.
+func isHTMLQuote(n *html.Node) bool {
+	switch {
+	case n.DataAtom == atom.Blockquote && strings.EqualFold(attr(n, "type"), "cite"):
+		return true
+	case hasClass(n, "gmail_quote"), hasClass(n, "gmail_quote_container"), hasClass(n, "moz-cite-prefix"), hasClass(n, "yahoo_quoted"):
+		return true
+	}
+	switch attr(n, "id") {
+	case "appendonsend", "divRplyFwdMsg", "stopSpelling":
+		return true
+	}
+	return false
+}
+
+// isHTMLSignature tells whether an element holds the sender's signature.
+func isHTMLSignature(n *html.Node) bool {
+	return hasClass(n, "gmail_signature") || hasClass(n, "moz-signature") || attr(n, "id") == "Signature"
+}
+
+// cutFrom removes n and everything after it in document order.
+func cutFrom(n *html.Node) {
+	removeFollowing(n)
+	parent := n.Parent
+	parent.RemoveChild(n)
+	for p := parent; p.Parent != nil && p.DataAtom != atom.Body; p = p.Parent {
+		removeFollowing(p)
+	}
+}
+
+// removeFollowing removes the siblings after n.
+func removeFollowing(n *html.Node) {
+	for next := n.NextSibling; next != nil; {
+		following := next.NextSibling
+		n.Parent.RemoveChild(next)
+		next = following
+	}
+}
+
+// render writes the document, or only the contents of its body when the
+// original was a fragment.
+func render(buf *bytes.Buffer, doc *html.Node, document bool) error {
+	if document {
+		return html.Render(buf, doc)
+	}
+	body := findBody(doc)
+	if body == nil {
+		return html.Render(buf, doc)
+	}
+	for c := body.FirstChild; c != nil; c = c.NextSibling {
+		if err := html.Render(buf, c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func findBody(n *html.Node) *html.Node {
+	if n.Type == html.ElementNode && n.DataAtom == atom.Body {
+		return n
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if b := findBody(c); b != nil {
+			return b
+		}
+	}
+	return nil
+}
+
+func previousElement(n *html.Node) *html.Node {
+	for p := n.PrevSibling; p != nil; p = p.PrevSibling {
+		if p.Type == html.ElementNode {
+			return p
+		}
+		if p.Type == html.TextNode && strings.TrimSpace(p.Data) != "" {
+			return nil
+		}
+	}
+	return nil
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if strings.EqualFold(a.Key, key) {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func hasClass(n *html.Node, class string) bool {
+	for _, c := range strings.Fields(attr(n, "class")) {
+		if c == class {
+			return true
+		}
+	}
+	return false
+}
+
+// textContent returns the text of a node and its descendants.
+func textContent(n *html.Node) string {
+	var sb strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			sb.WriteString(n.Data)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return sb.String()
+}
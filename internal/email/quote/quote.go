@@ -0,0 +1,144 @@
+// Package quote finds the text a sender quoted from earlier messages, and
+// their signature, in the body of an inbound email, so that only what they
+// wrote themselves is indexed and shown by default.
+package quote
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Result is a message body split into what the sender wrote and what
+// follows it.
+type Result struct {
+	// Visible is the body without quoted messages and signature, in the
+	// format of the body. It is the whole body when nothing was found.
+	Visible string
+	// HasQuote tells whether quoted messages were removed.
+	HasQuote bool
+	// HasSignature tells whether a signature was removed.
+	HasSignature bool
+}
+
+// Collapsed tells whether anything was removed from the body.
+func (r Result) Collapsed() bool { return r.HasQuote || r.HasSignature }
+
+// Split finds quoted messages and the signature in a body of the given
+// content type. HTML bodies are split at the quote markup of common mail
+// clients; other bodies are read as plain text.
+func Split(body, contentType string) Result {
+	if strings.Contains(strings.ToLower(contentType), "html") {
+		return splitHTML(body)
+	}
+	return splitText(body)
+}
+
+var (
+	// attributionPattern matches the line mail clients put above a quoted
+	// reply, such as "On Mon, 3 Feb 2025 at 10:00, Ann <ann@example.com> wrote:".
+	attributionPattern = regexp.MustCompile(`(?i)^(on\s.+\swrote|am\s.+\sschrieb\s.+|le\s.+\sa\s+écrit|el\s.+\sescribió|op\s.+\sschreef\s.+)\s?:$`)
+	// originalMessagePattern matches the separator Outlook and others put
+	// above the original message.
+	originalMessagePattern = regexp.MustCompile(`(?i)^-{2,}\s*(original message|ursprüngliche nachricht|message d'origine|mensaje original)\s*-{2,}$`)
+	// headerFromPattern and headerDetailPattern match the header block
+	// Outlook quotes the original message with.
+	headerFromPattern   = regexp.MustCompile(`(?i)^\*?(from|von|de):\*?\s`)
+	headerDetailPattern = regexp.MustCompile(`(?i)^\*?(sent|date|to|subject|gesendet|datum|an|betreff|envoyé|à|objet|enviado|para|asunto):\*?\s`)
+	// mobileSignaturePattern matches the signatures mobile mail apps add.
+	mobileSignaturePattern = regexp.MustCompile(`(?i)^(sent from my\s.+|get outlook for\s.+|von meinem\s.+\sgesendet|envoyé de mon\s.+)$`)
+)
+
+// splitText splits a plain text body at the first quoted message and the
+// signature above it.
+func splitText(body string) Result {
+	lines := strings.Split(strings.ReplaceAll(body, "\r\n", "\n"), "\n")
+
+	quoteAt := len(lines)
+	for i := range lines {
+		if isQuoteStart(lines, i) {
+			quoteAt = i
+			break
+		}
+	}
+	if at := trailingQuoteBlock(lines[:quoteAt]); at < quoteAt {
+		quoteAt = at
+	}
+
+	cut := quoteAt
+	sigAt := -1
+	for i := 0; i < quoteAt; i++ {
+		line := strings.TrimRight(lines[i], "\r")
+		if line == "-- " || line == "--" || mobileSignaturePattern.MatchString(strings.TrimSpace(line)) {
+			sigAt = i
+			cut = i
+			break
+		}
+	}
+
+	res := Result{Visible: body}
+	if cut == len(lines) {
+		return res
+	}
+	visible := strings.TrimRight(strings.Join(lines[:cut], "\n"), " \t\r\n")
+	if strings.TrimSpace(visible) == "" {
+		// A message that is all quote or signature is shown as it is
+		return res
+	}
+	res.Visible = visible
+	res.HasQuote = quoteAt < len(lines)
+	res.HasSignature = sigAt >= 0
+	return res
+}
+
+// isQuoteStart tells whether line i starts a quoted message.
+func isQuoteStart(lines []string, i int) bool {
+	line := strings.TrimSpace(lines[i])
+	if line == "" {
+		return false
+	}
+	if attributionPattern.MatchString(line) || originalMessagePattern.MatchString(line) {
+		return true
+	}
+	// Attributions of long names and addresses wrap onto a second line
+	if i+1 < len(lines) && !strings.HasSuffix(line, ":") {
+		if next := strings.TrimSpace(lines[i+1]); next != "" && attributionPattern.MatchString(line+" "+next) {
+			return true
+		}
+	}
+	if len(line) >= 20 && strings.Trim(line, "_") == "" {
+		return i+1 < len(lines) && headerFromPattern.MatchString(strings.TrimSpace(lines[i+1]))
+	}
+	if headerFromPattern.MatchString(line) {
+		details := 0
+		for j := i + 1; j < len(lines) && j <= i+4; j++ {
+			if headerDetailPattern.MatchString(strings.TrimSpace(lines[j])) {
+				details++
+			}
+		}
+		return details >= 2
+	}
+	return false
+}
+
+// trailingQuoteBlock returns the first line of the "> " quoted lines that
+// end the text, or len(lines) if it does not end in one. Quotes the sender
+// answered inline are followed by their answer and are kept.
+func trailingQuoteBlock(lines []string) int {
+	at := len(lines)
+	quoted := false
+	for i := len(lines) - 1; i >= 0; i-- {
+		line := strings.TrimSpace(lines[i])
+		if line == "" {
+			continue
+		}
+		if !strings.HasPrefix(line, ">") {
+			break
+		}
+		quoted = true
+		at = i
+	}
+	if !quoted {
+		return len(lines)
+	}
+	return at
+}
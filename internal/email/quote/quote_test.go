@@ -0,0 +1,156 @@
+package quote
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplit_Text(t *testing.T) {
+	tests := []struct {
+		name      string
+		body      string
+		visible   string
+		quote     bool
+		signature bool
+	}{
+		{
+			name:    "nothing to remove",
+			body:    "The printer is still broken.\n\nAnn",
+			visible: "The printer is still broken.\n\nAnn",
+		},
+		{
+			name:    "gmail attribution",
+			body:    "Thanks, that fixed it.\r\n\r\nOn Mon, 3 Feb 2025 at 10:00, Support <support@example.com> wrote:\r\n> Please restart the printer.\r\n",
+			visible: "Thanks, that fixed it.",
+			quote:   true,
+		},
+		{
+			name:    "wrapped attribution",
+			body:    "Thanks.\n\nOn Mon, 3 Feb 2025 at 10:00, Example Support Team <support@example.com>\nwrote:\n> Please restart the printer.",
+			visible: "Thanks.",
+			quote:   true,
+		},
+		{
+			name:    "german attribution",
+			body:    "Danke!\n\nAm 03.02.2025 um 10:00 schrieb Support <support@example.com>:\n> Bitte neu starten.",
+			visible: "Danke!",
+			quote:   true,
+		},
+		{
+			name:    "outlook original message",
+			body:    "It works now.\n\n-----Original Message-----\nFrom: Support\nSent: Monday\nPlease restart.",
+			visible: "It works now.",
+			quote:   true,
+		},
+		{
+			name:    "outlook header block",
+			body:    "It works now.\n\n________________________________\nFrom: Support <support@example.com>\nSent: Monday, February 3, 2025 10:00\nTo: Ann\nSubject: Printer\n\nPlease restart.",
+			visible: "It works now.",
+			quote:   true,
+		},
+		{
+			name:    "header block without separator",
+			body:    "It works now.\n\nFrom: Support <support@example.com>\nDate: Monday, February 3, 2025 10:00\nTo: Ann\n\nPlease restart.",
+			visible: "It works now.",
+			quote:   true,
+		},
+		{
+			name:    "trailing quote",
+			body:    "Still broken.\n\n> Please restart the printer.\n> Regards\n",
+			visible: "Still broken.",
+			quote:   true,
+		},
+		{
+			name:    "inline answers are kept",
+			body:    "> Did you restart it?\nYes.\n> Which model?\nLaserJet 4000.",
+			visible: "> Did you restart it?\nYes.\n> Which model?\nLaserJet 4000.",
+		},
+		{
+			name:      "signature and quote",
+			body:      "Still broken.\n\n-- \nAnn Example\nExample Corp\n\nOn Mon, 3 Feb 2025, Support wrote:\n> Restart it.",
+			visible:   "Still broken.",
+			quote:     true,
+			signature: true,
+		},
+		{
+			name:      "mobile signature",
+			body:      "Will do.\n\nSent from my iPhone",
+			visible:   "Will do.",
+			signature: true,
+		},
+		{
+			name:    "only a quote",
+			body:    "On Mon, 3 Feb 2025, Support wrote:\n> Restart it.",
+			visible: "On Mon, 3 Feb 2025, Support wrote:\n> Restart it.",
+		},
+		{
+			name:    "forwarded messages are kept",
+			body:    "See below.\n\n---------- Forwarded message ---------\nFrom: Bob\nBroken again.",
+			visible: "See below.\n\n---------- Forwarded message ---------\nFrom: Bob\nBroken again.",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := Split(tt.body, "text/plain; charset=utf-8")
+			assert.Equal(t, tt.visible, res.Visible)
+			assert.Equal(t, tt.quote, res.HasQuote, "quote")
+			assert.Equal(t, tt.signature, res.HasSignature, "signature")
+			assert.Equal(t, tt.quote || tt.signature, res.Collapsed())
+		})
+	}
+}
+
+func TestSplit_HTML(t *testing.T) {
+	tests := []struct {
+		name      string
+		body      string
+		visible   string
+		quote     bool
+		signature bool
+	}{
+		{
+			name:    "gmail",
+			body:    `<div dir="ltr">Thanks!</div><br><div class="gmail_quote"><div class="gmail_attr">On Mon, Support wrote:</div><blockquote class="gmail_quote">Restart it.</blockquote></div>`,
+			visible: `<div dir="ltr">Thanks!</div><br/>`,
+			quote:   true,
+		},
+		{
+			name:      "gmail signature",
+			body:      `<div dir="ltr">Thanks!<br clear="all"><div><div class="gmail_signature">Ann</div></div></div><div class="gmail_quote">old</div>`,
+			visible:   `<div dir="ltr">Thanks!<br clear="all"/><div></div></div>`,
+			quote:     true,
+			signature: true,
+		},
+		{
+			name:    "apple mail",
+			body:    `<div>Fixed.</div><div><br><div>On 3 Feb 2025, at 10:00, Support &lt;support@example.com&gt; wrote:</div><blockquote type="cite"><div>Restart it.</div></blockquote></div>`,
+			visible: `<div>Fixed.</div><div><br/></div>`,
+			quote:   true,
+		},
+		{
+			name:    "outlook",
+			body:    `<html><head></head><body><div>Works.</div><div id="appendonsend"></div><hr><div id="divRplyFwdMsg">From: Support</div><div>Restart it.</div></body></html>`,
+			visible: `<html><head></head><body><div>Works.</div></body></html>`,
+			quote:   true,
+		},
+		{
+			name:    "plain html",
+			body:    `<p>No quote here.</p>`,
+			visible: `<p>No quote here.</p>`,
+		},
+		{
+			name:    "only a quote",
+			body:    `<blockquote type="cite">Restart it.</blockquote>`,
+			visible: `<blockquote type="cite">Restart it.</blockquote>`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := Split(tt.body, "text/html")
+			assert.Equal(t, tt.visible, res.Visible)
+			assert.Equal(t, tt.quote, res.HasQuote, "quote")
+			assert.Equal(t, tt.signature, res.HasSignature, "signature")
+		})
+	}
+}
@@ -0,0 +1,179 @@
+// Package events provides an in-process hub that pushes ticket, queue and
+// plugin events to connected clients.
+package events
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// Event types published on the hub.
+const (
+	TypeTicketCreated      = "ticket.created"
+	TypeTicketUpdated      = "ticket.updated"
+	TypeTicketApproval     = "ticket.approval"
+	TypeQueueCounts        = "queue.counts"
+	TypePluginRegistered   = "plugin.registered"
+	TypePluginUnregistered = "plugin.unregistered"
+	TypePluginEnabled      = "plugin.enabled"
+	TypePluginDisabled     = "plugin.disabled"
+	TypePluginTemplates    = "plugin.templates"
+)
+
+// Event is a single change pushed to subscribers. QueueID scopes ticket and
+// queue events; events without a queue are visible to every agent.
+type Event struct {
+	ID       uint64    `json:"id"`
+	Type     string    `json:"type"`
+	QueueID  int       `json:"queue_id,omitempty"`
+	TicketID int       `json:"ticket_id,omitempty"`
+	Plugin   string    `json:"plugin,omitempty"`
+	Data     any       `json:"data,omitempty"`
+	Time     time.Time `json:"time"`
+}
+
+// Category returns the part of the type before the dot, e.g. "ticket".
+func (e Event) Category() string {
+	category, _, _ := strings.Cut(e.Type, ".")
+	return category
+}
+
+const (
+	defaultBacklog = 256 // Events kept for clients resuming with Last-Event-ID
+	subscriberBuf  = 64
+)
+
+// Hub fans events out to subscribers. Slow subscribers lose events rather
+// than blocking publishers.
+type Hub struct {
+	mu      sync.RWMutex
+	subs    map[*Subscription]struct{}
+	nextID  uint64
+	backlog []Event
+	limit   int
+}
+
+// Subscription receives the events its filter allows.
+type Subscription struct {
+	hub     *Hub
+	allow   func(Event) bool
+	ch      chan Event
+	mu      sync.Mutex // Guards ch against sends after Close
+	closed  bool
+	dropped uint64
+}
+
+// NewHub creates an empty hub.
+func NewHub() *Hub {
+	return &Hub{
+		subs:  make(map[*Subscription]struct{}),
+		limit: defaultBacklog,
+	}
+}
+
+var defaultHub = NewHub()
+
+// Default returns the process-wide hub.
+func Default() *Hub {
+	return defaultHub
+}
+
+// Publish sends an event on the process-wide hub.
+func Publish(e Event) {
+	defaultHub.Publish(e)
+}
+
+// Publish assigns the event an ID and delivers it to every subscriber whose
+// filter allows it.
+func (h *Hub) Publish(e Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now().UTC()
+	}
+
+	h.mu.Lock()
+	h.nextID++
+	e.ID = h.nextID
+	h.backlog = append(h.backlog, e)
+	if len(h.backlog) > h.limit {
+		h.backlog = h.backlog[len(h.backlog)-h.limit:]
+	}
+	subs := make([]*Subscription, 0, len(h.subs))
+	for s := range h.subs {
+		subs = append(subs, s)
+	}
+	h.mu.Unlock()
+
+	for _, s := range subs {
+		s.deliver(e)
+	}
+}
+
+// Subscribe registers a subscriber. allow may be nil to receive everything.
+// Events newer than lastID still in the backlog are delivered first, so a
+// reconnecting client does not miss updates.
+func (h *Hub) Subscribe(allow func(Event) bool, lastID uint64) *Subscription {
+	s := &Subscription{hub: h, allow: allow, ch: make(chan Event, subscriberBuf)}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if lastID > 0 {
+		for _, e := range h.backlog {
+			if e.ID > lastID {
+				s.deliver(e)
+			}
+		}
+	}
+	h.subs[s] = struct{}{}
+	return s
+}
+
+// Subscribers returns the number of active subscriptions.
+func (h *Hub) Subscribers() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.subs)
+}
+
+// Events returns the channel events are delivered on. It is closed by Close.
+func (s *Subscription) Events() <-chan Event {
+	return s.ch
+}
+
+// Dropped returns how many events were discarded because the subscriber
+// was not keeping up.
+func (s *Subscription) Dropped() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.dropped
+}
+
+// Close unregisters the subscription and closes its channel.
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	delete(s.hub.subs, s)
+	s.hub.mu.Unlock()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.closed {
+		s.closed = true
+		close(s.ch)
+	}
+}
+
+func (s *Subscription) deliver(e Event) {
+	if s.allow != nil && !s.allow(e) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	select {
+	case s.ch <- e:
+	default:
+		s.dropped++
+	}
+}
@@ -0,0 +1,89 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func receive(t *testing.T, sub *Subscription) []Event {
+	t.Helper()
+	var got []Event
+	for {
+		select {
+		case e := <-sub.Events():
+			got = append(got, e)
+		default:
+			return got
+		}
+	}
+}
+
+func TestHubFiltersBySubscriber(t *testing.T) {
+	hub := NewHub()
+	queue1 := hub.Subscribe(func(e Event) bool { return e.QueueID == 0 || e.QueueID == 1 }, 0)
+	everything := hub.Subscribe(nil, 0)
+	defer queue1.Close()
+	defer everything.Close()
+
+	hub.Publish(Event{Type: TypeTicketUpdated, QueueID: 1, TicketID: 10})
+	hub.Publish(Event{Type: TypeTicketUpdated, QueueID: 2, TicketID: 20})
+	hub.Publish(Event{Type: TypePluginEnabled, Plugin: "stats"})
+
+	got := receive(t, queue1)
+	require.Len(t, got, 2)
+	assert.Equal(t, 10, got[0].TicketID)
+	assert.Equal(t, "stats", got[1].Plugin)
+	assert.Equal(t, "plugin", got[1].Category())
+	assert.False(t, got[0].Time.IsZero())
+
+	all := receive(t, everything)
+	require.Len(t, all, 3)
+	assert.Equal(t, []uint64{1, 2, 3}, []uint64{all[0].ID, all[1].ID, all[2].ID})
+}
+
+func TestHubReplaysBacklogAfterLastID(t *testing.T) {
+	hub := NewHub()
+	for i := 1; i <= 5; i++ {
+		hub.Publish(Event{Type: TypeTicketUpdated, QueueID: i % 2, TicketID: i})
+	}
+
+	sub := hub.Subscribe(func(e Event) bool { return e.QueueID == 1 }, 2)
+	defer sub.Close()
+
+	got := receive(t, sub)
+	require.Len(t, got, 2)
+	assert.Equal(t, 3, got[0].TicketID)
+	assert.Equal(t, 5, got[1].TicketID)
+
+	fresh := hub.Subscribe(nil, 0)
+	defer fresh.Close()
+	assert.Empty(t, receive(t, fresh), "subscribers without Last-Event-ID only get new events")
+}
+
+func TestHubDropsForSlowSubscribers(t *testing.T) {
+	hub := NewHub()
+	sub := hub.Subscribe(nil, 0)
+	defer sub.Close()
+
+	for i := 0; i < subscriberBuf+5; i++ {
+		hub.Publish(Event{Type: TypeTicketUpdated})
+	}
+	assert.Len(t, receive(t, sub), subscriberBuf)
+	assert.Equal(t, uint64(5), sub.Dropped())
+}
+
+func TestSubscriptionClose(t *testing.T) {
+	hub := NewHub()
+	sub := hub.Subscribe(nil, 0)
+	assert.Equal(t, 1, hub.Subscribers())
+
+	sub.Close()
+	sub.Close()
+	assert.Equal(t, 0, hub.Subscribers())
+
+	hub.Publish(Event{Type: TypeTicketUpdated})
+	_, ok := <-sub.Events()
+	assert.False(t, ok, "channel is closed")
+}
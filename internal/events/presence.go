@@ -0,0 +1,185 @@
+package events
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// TypeTicketPresence reports the agents currently viewing a ticket.
+const TypeTicketPresence = "ticket.presence"
+
+// DefaultPresenceTTL is how long a viewer stays listed without a heartbeat.
+const DefaultPresenceTTL = 60 * time.Second
+
+// Viewer is an agent who has a ticket open.
+type Viewer struct {
+	UserID    int       `json:"user_id"`
+	Name      string    `json:"name"`
+	Composing bool      `json:"composing"` // Has the reply or note form open
+	Since     time.Time `json:"since"`
+	seen      time.Time
+}
+
+// Presence tracks who is viewing which ticket and publishes a
+// ticket.presence event whenever the set of viewers changes. State is kept
+// in memory, so each server process reports its own viewers.
+type Presence struct {
+	mu      sync.Mutex
+	hub     *Hub
+	ttl     time.Duration
+	now     func() time.Time
+	tickets map[int]*ticketViewers
+}
+
+type ticketViewers struct {
+	queueID int
+	viewers map[int]*Viewer
+}
+
+// NewPresence creates a tracker that publishes on hub.
+func NewPresence(hub *Hub, ttl time.Duration) *Presence {
+	if ttl <= 0 {
+		ttl = DefaultPresenceTTL
+	}
+	return &Presence{hub: hub, ttl: ttl, now: time.Now, tickets: make(map[int]*ticketViewers)}
+}
+
+var defaultPresence = NewPresence(defaultHub, DefaultPresenceTTL)
+
+// DefaultPresence returns the process-wide tracker.
+func DefaultPresence() *Presence {
+	return defaultPresence
+}
+
+// Touch records a heartbeat from an agent viewing the ticket and returns
+// the current viewers.
+func (p *Presence) Touch(ticketID, queueID, userID int, name string, composing bool) []Viewer {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.now()
+	tv := p.tickets[ticketID]
+	if tv == nil {
+		tv = &ticketViewers{viewers: make(map[int]*Viewer)}
+		p.tickets[ticketID] = tv
+	}
+	tv.queueID = queueID
+	changed := p.prune(tv, now)
+
+	v := tv.viewers[userID]
+	if v == nil {
+		v = &Viewer{UserID: userID, Since: now.UTC()}
+		tv.viewers[userID] = v
+		changed = true
+	}
+	if v.Composing != composing || v.Name != name {
+		changed = true
+	}
+	v.Name, v.Composing, v.seen = name, composing, now
+
+	viewers := tv.list()
+	if changed {
+		p.publish(ticketID, tv.queueID, viewers)
+	}
+	return viewers
+}
+
+// Leave removes the agent from the ticket's viewers.
+func (p *Presence) Leave(ticketID, userID int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	tv := p.tickets[ticketID]
+	if tv == nil {
+		return
+	}
+	if _, ok := tv.viewers[userID]; !ok {
+		return
+	}
+	delete(tv.viewers, userID)
+	p.publish(ticketID, tv.queueID, tv.list())
+	if len(tv.viewers) == 0 {
+		delete(p.tickets, ticketID)
+	}
+}
+
+// Viewers returns the agents viewing the ticket, oldest first.
+func (p *Presence) Viewers(ticketID int) []Viewer {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	tv := p.tickets[ticketID]
+	if tv == nil {
+		return []Viewer{}
+	}
+	if p.prune(tv, p.now()) {
+		p.publish(ticketID, tv.queueID, tv.list())
+	}
+	return tv.list()
+}
+
+// Sweep drops viewers whose heartbeat has lapsed, e.g. after a closed tab.
+func (p *Presence) Sweep() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := p.now()
+	for ticketID, tv := range p.tickets {
+		if p.prune(tv, now) {
+			p.publish(ticketID, tv.queueID, tv.list())
+		}
+		if len(tv.viewers) == 0 {
+			delete(p.tickets, ticketID)
+		}
+	}
+}
+
+// Run sweeps every TTL until ctx is cancelled.
+func (p *Presence) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.ttl)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			p.Sweep()
+		}
+	}
+}
+
+func (p *Presence) prune(tv *ticketViewers, now time.Time) bool {
+	changed := false
+	for id, v := range tv.viewers {
+		if now.Sub(v.seen) > p.ttl {
+			delete(tv.viewers, id)
+			changed = true
+		}
+	}
+	return changed
+}
+
+func (p *Presence) publish(ticketID, queueID int, viewers []Viewer) {
+	p.hub.Publish(Event{
+		Type:     TypeTicketPresence,
+		QueueID:  queueID,
+		TicketID: ticketID,
+		Data:     map[string]any{"viewers": viewers},
+	})
+}
+
+func (tv *ticketViewers) list() []Viewer {
+	viewers := make([]Viewer, 0, len(tv.viewers))
+	for _, v := range tv.viewers {
+		viewers = append(viewers, *v)
+	}
+	sort.Slice(viewers, func(i, j int) bool {
+		if !viewers[i].Since.Equal(viewers[j].Since) {
+			return viewers[i].Since.Before(viewers[j].Since)
+		}
+		return viewers[i].UserID < viewers[j].UserID
+	})
+	return viewers
+}
@@ -0,0 +1,83 @@
+package events
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestPresence() (*Presence, *Hub, *time.Time) {
+	hub := NewHub()
+	p := NewPresence(hub, time.Minute)
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	p.now = func() time.Time { return now }
+	return p, hub, &now
+}
+
+func TestPresenceTouchPublishesChanges(t *testing.T) {
+	p, hub, now := newTestPresence()
+	sub := hub.Subscribe(nil, 0)
+	defer sub.Close()
+
+	viewers := p.Touch(10, 1, 5, "Ann Agent", false)
+	require.Len(t, viewers, 1)
+	assert.Equal(t, "Ann Agent", viewers[0].Name)
+
+	*now = now.Add(time.Second)
+	p.Touch(10, 1, 6, "Bob Agent", false)
+	// A heartbeat without changes is not published.
+	p.Touch(10, 1, 5, "Ann Agent", false)
+	p.Touch(10, 1, 5, "Ann Agent", true)
+
+	got := receive(t, sub)
+	require.Len(t, got, 3)
+	for _, e := range got {
+		assert.Equal(t, TypeTicketPresence, e.Type)
+		assert.Equal(t, 1, e.QueueID)
+		assert.Equal(t, 10, e.TicketID)
+	}
+	last := got[2].Data.(map[string]any)["viewers"].([]Viewer)
+	require.Len(t, last, 2)
+	assert.Equal(t, 5, last[0].UserID)
+	assert.True(t, last[0].Composing)
+	assert.Equal(t, 6, last[1].UserID)
+}
+
+func TestPresenceLeave(t *testing.T) {
+	p, hub, _ := newTestPresence()
+	p.Touch(10, 1, 5, "Ann Agent", false)
+	sub := hub.Subscribe(nil, 0)
+	defer sub.Close()
+
+	p.Leave(10, 5)
+	p.Leave(10, 5)
+
+	got := receive(t, sub)
+	require.Len(t, got, 1)
+	assert.Empty(t, got[0].Data.(map[string]any)["viewers"])
+	assert.Empty(t, p.Viewers(10))
+}
+
+func TestPresenceSweepDropsLapsedViewers(t *testing.T) {
+	p, hub, now := newTestPresence()
+	p.Touch(10, 1, 5, "Ann Agent", false)
+	*now = now.Add(45 * time.Second)
+	p.Touch(10, 1, 6, "Bob Agent", false)
+	sub := hub.Subscribe(nil, 0)
+	defer sub.Close()
+
+	*now = now.Add(30 * time.Second)
+	p.Sweep()
+
+	viewers := p.Viewers(10)
+	require.Len(t, viewers, 1)
+	assert.Equal(t, 6, viewers[0].UserID)
+	assert.Len(t, receive(t, sub), 1)
+
+	*now = now.Add(time.Minute)
+	p.Sweep()
+	assert.Empty(t, p.Viewers(10))
+	assert.Empty(t, p.tickets)
+}
@@ -0,0 +1,117 @@
+package events
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+)
+
+// QueueCounts are the open ticket counters of one queue.
+type QueueCounts struct {
+	New     int `json:"new"`
+	Open    int `json:"open"`
+	Pending int `json:"pending"`
+	Total   int `json:"total"`
+}
+
+// queueCountsQuery counts unresolved tickets per queue by state type, so
+// renamed or custom states still land in the right counter.
+const queueCountsQuery = `
+	SELECT t.queue_id, tst.name, COUNT(*)
+	FROM ticket t
+	JOIN ticket_state ts ON t.ticket_state_id = ts.id
+	JOIN ticket_state_type tst ON ts.type_id = tst.id
+	WHERE tst.name IN ('new', 'open', 'pending reminder', 'pending auto')
+	GROUP BY t.queue_id, tst.name`
+
+// LoadQueueCounts returns the open ticket counters of every queue with
+// unresolved tickets.
+func LoadQueueCounts(ctx context.Context, db *sql.DB) (map[int]QueueCounts, error) {
+	rows, err := db.QueryContext(ctx, queueCountsQuery)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[int]QueueCounts)
+	for rows.Next() {
+		var queueID, n int
+		var stateType string
+		if err := rows.Scan(&queueID, &stateType, &n); err != nil {
+			return nil, err
+		}
+		c := counts[queueID]
+		switch stateType {
+		case "new":
+			c.New += n
+		case "open":
+			c.Open += n
+		default:
+			c.Pending += n
+		}
+		c.Total += n
+		counts[queueID] = c
+	}
+	return counts, rows.Err()
+}
+
+// QueueCountPublisher polls the queue counters and publishes a queue.counts
+// event for each queue whose counters changed. Polling also picks up changes
+// made by other processes, such as the runner's postmaster.
+type QueueCountPublisher struct {
+	db       *sql.DB
+	hub      *Hub
+	interval time.Duration
+	last     map[int]QueueCounts
+}
+
+// NewQueueCountPublisher creates a publisher for hub.
+func NewQueueCountPublisher(db *sql.DB, hub *Hub, interval time.Duration) *QueueCountPublisher {
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	return &QueueCountPublisher{db: db, hub: hub, interval: interval, last: make(map[int]QueueCounts)}
+}
+
+// Run polls until ctx is cancelled. Nothing is queried while no client is
+// subscribed; the first poll after a quiet period republishes every queue.
+func (p *QueueCountPublisher) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if p.hub.Subscribers() == 0 {
+				p.last = make(map[int]QueueCounts)
+				continue
+			}
+			if err := p.Poll(ctx); err != nil {
+				log.Printf("events: queue counter poll failed: %v", err)
+			}
+		}
+	}
+}
+
+// Poll publishes the queues whose counters changed since the last poll.
+func (p *QueueCountPublisher) Poll(ctx context.Context) error {
+	counts, err := LoadQueueCounts(ctx, p.db)
+	if err != nil {
+		return err
+	}
+	for queueID, c := range counts {
+		if prev, ok := p.last[queueID]; !ok || prev != c {
+			p.hub.Publish(Event{Type: TypeQueueCounts, QueueID: queueID, Data: c})
+		}
+	}
+	// Queues that emptied out drop from the query result.
+	for queueID := range p.last {
+		if _, ok := counts[queueID]; !ok {
+			p.hub.Publish(Event{Type: TypeQueueCounts, QueueID: queueID, Data: QueueCounts{}})
+		}
+	}
+	p.last = counts
+	return nil
+}
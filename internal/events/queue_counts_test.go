@@ -0,0 +1,63 @@
+package events
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/testutil"
+)
+
+// newCountsTestDB returns a migrated database with, by the seeded states,
+// a new, two open and a closed ticket in queue 1 and a pending one in queue 2.
+func newCountsTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db := testutil.MigratedDB(t)
+	for i, ticket := range []struct{ queueID, stateID int }{{1, 1}, {1, 2}, {1, 2}, {1, 4}, {2, 3}} {
+		_, err := db.Exec(`INSERT INTO ticket (tn, title, queue_id, ticket_lock_id, user_id, responsible_user_id,
+			ticket_priority_id, ticket_state_id, timeout, until_time, escalation_time, escalation_update_time,
+			escalation_response_time, escalation_solution_time, archive_flag, create_time, create_by, change_time, change_by)
+			VALUES (?, 'Printer', ?, 1, 1, 1, 3, ?, 0, 0, 0, 0, 0, 0, 0, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+			1001+i, ticket.queueID, ticket.stateID)
+		require.NoError(t, err)
+	}
+	return db
+}
+
+func TestLoadQueueCounts(t *testing.T) {
+	db := newCountsTestDB(t)
+
+	counts, err := LoadQueueCounts(context.Background(), db)
+	require.NoError(t, err)
+	assert.Equal(t, map[int]QueueCounts{
+		1: {New: 1, Open: 2, Total: 3},
+		2: {Pending: 1, Total: 1},
+	}, counts)
+}
+
+func TestQueueCountPublisherPublishesChanges(t *testing.T) {
+	db := newCountsTestDB(t)
+	hub := NewHub()
+	sub := hub.Subscribe(nil, 0)
+	defer sub.Close()
+	p := NewQueueCountPublisher(db, hub, 0)
+	ctx := context.Background()
+
+	require.NoError(t, p.Poll(ctx))
+	assert.Len(t, receive(t, sub), 2, "first poll publishes every queue")
+
+	require.NoError(t, p.Poll(ctx))
+	assert.Empty(t, receive(t, sub), "unchanged counters are not republished")
+
+	_, err := db.Exec(`UPDATE ticket SET ticket_state_id = 4 WHERE queue_id = 2`)
+	require.NoError(t, err)
+	require.NoError(t, p.Poll(ctx))
+	got := receive(t, sub)
+	require.Len(t, got, 1)
+	assert.Equal(t, TypeQueueCounts, got[0].Type)
+	assert.Equal(t, 2, got[0].QueueID)
+	assert.Equal(t, QueueCounts{}, got[0].Data, "emptied queue reports zero")
+}
@@ -0,0 +1,576 @@
+package graphql
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// objectType is a GraphQL object type with its fields.
+type objectType struct {
+	name   string
+	fields map[string]*fieldDef
+}
+
+// fieldDef describes a field of an object type. Fields are resolved for all
+// parent objects at once, so related records load in one query per level
+// instead of one per parent.
+type fieldDef struct {
+	typ  string // GraphQL type, e.g. [Article!]!
+	args map[string]argDef
+	// resolve returns one value per parent. Object values are resolved
+	// further with the field's selection set; nil is returned as null.
+	resolve func(r *request, parents []interface{}, args map[string]interface{}) ([]interface{}, error)
+}
+
+type argDef struct {
+	typ string
+	def interface{}
+}
+
+type schema struct {
+	query *objectType
+	types map[string]*objectType
+}
+
+// namedType strips list and non-null markers from a type.
+func namedType(typ string) string {
+	return strings.Trim(typ, "[]!")
+}
+
+// Error is a GraphQL error as returned in the errors list.
+type Error struct {
+	Message   string        `json:"message"`
+	Locations []Location    `json:"locations,omitempty"`
+	Path      []interface{} `json:"path,omitempty"`
+}
+
+// Response is the result of executing a GraphQL request. Data is nil when
+// the request could not be executed at all.
+type Response struct {
+	Data   *object  `json:"data,omitempty"`
+	Errors []*Error `json:"errors,omitempty"`
+}
+
+func requestError(format string, args ...interface{}) *Response {
+	return &Response{Errors: []*Error{{Message: fmt.Sprintf(format, args...)}}}
+}
+
+// object is a result object; its keys keep the order of the query.
+type object struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func newObject() *object {
+	return &object{values: map[string]interface{}{}}
+}
+
+func (o *object) set(key string, v interface{}) {
+	if _, ok := o.values[key]; !ok {
+		o.keys = append(o.keys, key)
+	}
+	o.values[key] = v
+}
+
+// Get returns the value under key, for tests.
+func (o *object) Get(key string) interface{} {
+	return o.values[key]
+}
+
+// MarshalJSON writes the object with its keys in query order.
+func (o *object) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, k := range o.keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, err := json.Marshal(k)
+		if err != nil {
+			return nil, err
+		}
+		val, err := json.Marshal(o.values[k])
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(val)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+type executor struct {
+	schema     *schema
+	doc        *document
+	vars       map[string]interface{}
+	req        *request
+	maxDepth   int
+	maxFields  int
+	maxAliases int
+	errors     []*Error
+}
+
+func (e *executor) fail(loc Location, path []interface{}, format string, args ...interface{}) {
+	e.errors = append(e.errors, &Error{
+		Message:   fmt.Sprintf(format, args...),
+		Locations: []Location{loc},
+		Path:      path,
+	})
+}
+
+// selectOperation picks the operation to run by name.
+func selectOperation(doc *document, name string) (*operation, error) {
+	if name == "" {
+		if len(doc.operations) > 1 {
+			return nil, fmt.Errorf("operationName is required for documents with several operations")
+		}
+		return doc.operations[0], nil
+	}
+	for _, op := range doc.operations {
+		if op.name == name {
+			return op, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown operation %q", name)
+}
+
+// coerceVariables checks the request's variables against the operation's
+// variable definitions and applies defaults.
+func coerceVariables(op *operation, input map[string]interface{}) (map[string]interface{}, error) {
+	vars := map[string]interface{}{}
+	for _, def := range op.vars {
+		v, ok := input[def.name]
+		if !ok && def.hasDefault {
+			v, ok = def.def.resolve(nil), true
+		}
+		if !ok && strings.HasSuffix(def.typ, "!") {
+			return nil, fmt.Errorf("variable $%s of type %s was not provided", def.name, def.typ)
+		}
+		if !ok {
+			continue
+		}
+		c, err := coerceInput(def.typ, v)
+		if err != nil {
+			return nil, fmt.Errorf("variable $%s: %w", def.name, err)
+		}
+		vars[def.name] = c
+	}
+	return vars, nil
+}
+
+// coerceInput converts a JSON or literal value to the Go value for typ:
+// int, float64, string, bool or a []interface{} of those.
+func coerceInput(typ string, v interface{}) (interface{}, error) {
+	nonNull := strings.HasSuffix(typ, "!")
+	typ = strings.TrimSuffix(typ, "!")
+	if v == nil {
+		if nonNull {
+			return nil, fmt.Errorf("expected %s!, got null", typ)
+		}
+		return nil, nil
+	}
+	if strings.HasPrefix(typ, "[") {
+		inner := typ[1 : len(typ)-1]
+		items, ok := v.([]interface{})
+		if !ok {
+			items = []interface{}{v}
+		}
+		list := make([]interface{}, len(items))
+		for i, item := range items {
+			c, err := coerceInput(inner, item)
+			if err != nil {
+				return nil, err
+			}
+			list[i] = c
+		}
+		return list, nil
+	}
+	switch typ {
+	case "Int":
+		var f float64
+		switch n := v.(type) {
+		case int:
+			return n, nil
+		case float64:
+			f = n
+		case json.Number:
+			var err error
+			if f, err = n.Float64(); err != nil {
+				return nil, fmt.Errorf("expected Int, got %s", n)
+			}
+		default:
+			return nil, fmt.Errorf("expected Int, got %v", v)
+		}
+		if f != math.Trunc(f) || math.Abs(f) > math.MaxInt32 {
+			return nil, fmt.Errorf("expected Int, got %v", v)
+		}
+		return int(f), nil
+	case "Float":
+		switch n := v.(type) {
+		case int:
+			return float64(n), nil
+		case float64:
+			return n, nil
+		case json.Number:
+			return n.Float64()
+		}
+	case "String":
+		if s, ok := v.(string); ok {
+			return s, nil
+		}
+	case "ID":
+		switch n := v.(type) {
+		case string:
+			return n, nil
+		case int:
+			return fmt.Sprint(n), nil
+		case float64:
+			if n == math.Trunc(n) {
+				return fmt.Sprint(int64(n)), nil
+			}
+		case json.Number:
+			return n.String(), nil
+		}
+	case "Boolean":
+		if b, ok := v.(bool); ok {
+			return b, nil
+		}
+	}
+	return nil, fmt.Errorf("expected %s, got %v", typ, v)
+}
+
+// validate checks the operation against the schema before anything runs.
+func (e *executor) validate(op *operation) []*Error {
+	var errs []*Error
+	add := func(loc Location, format string, args ...interface{}) {
+		errs = append(errs, &Error{Message: fmt.Sprintf(format, args...), Locations: []Location{loc}})
+	}
+	declared := map[string]bool{}
+	for _, v := range op.vars {
+		declared[v.name] = true
+	}
+	var checkValue func(v value, loc Location)
+	checkValue = func(v value, loc Location) {
+		switch v.kind {
+		case valueVariable:
+			if !declared[v.raw] {
+				add(loc, "variable $%s is not defined", v.raw)
+			}
+		case valueList:
+			for _, item := range v.list {
+				checkValue(item, loc)
+			}
+		case valueObject:
+			for _, f := range v.fields {
+				checkValue(f.value, loc)
+			}
+		}
+	}
+	checkDirectives := func(dirs []*directive) {
+		for _, d := range dirs {
+			if d.name != "skip" && d.name != "include" {
+				add(d.loc, "unknown directive @%s", d.name)
+				continue
+			}
+			if len(d.args) != 1 || d.args[0].name != "if" {
+				add(d.loc, "@%s requires the argument if", d.name)
+				continue
+			}
+			checkValue(d.args[0].value, d.args[0].loc)
+		}
+	}
+
+	// Fragments are validated once, against their type condition, and
+	// their cost is reused wherever they are spread. Walking a fragment at
+	// every spread would take time exponential in the nesting of fragments
+	// that spread others several times.
+	fragments := map[string]*fragmentInfo{}
+	var walk func(typ *objectType, sels []selection, depth int) selectionCost
+	walk = func(typ *objectType, sels []selection, depth int) selectionCost {
+		var cost selectionCost
+		for _, sel := range sels {
+			switch s := sel.(type) {
+			case *field:
+				checkDirectives(s.directives)
+				fieldCost := selectionCost{fields: 1, depth: 1}
+				if s.alias != "" {
+					fieldCost.aliases = 1
+				}
+				if s.name == "__typename" {
+					if len(s.selections) > 0 {
+						add(s.loc, "field __typename cannot have a selection set")
+					}
+					cost.add(fieldCost)
+					continue
+				}
+				if s.name == "__schema" || s.name == "__type" {
+					add(s.loc, "introspection is not supported; the schema is served at /api/graphql/schema")
+					continue
+				}
+				def, ok := typ.fields[s.name]
+				if !ok {
+					add(s.loc, "cannot query field %q on type %s", s.name, typ.name)
+					continue
+				}
+				if depth > e.maxDepth {
+					add(s.loc, "query is nested deeper than %d levels", e.maxDepth)
+					continue
+				}
+				seen := map[string]bool{}
+				for _, arg := range s.args {
+					if _, ok := def.args[arg.name]; !ok {
+						add(arg.loc, "unknown argument %q on field %s.%s", arg.name, typ.name, s.name)
+					} else if seen[arg.name] {
+						add(arg.loc, "argument %q given more than once", arg.name)
+					}
+					seen[arg.name] = true
+					checkValue(arg.value, arg.loc)
+				}
+				child, isObject := e.schema.types[namedType(def.typ)]
+				switch {
+				case isObject && len(s.selections) == 0:
+					add(s.loc, "field %s.%s of type %s must have a selection of subfields", typ.name, s.name, def.typ)
+				case !isObject && len(s.selections) > 0:
+					add(s.loc, "field %s.%s of type %s cannot have a selection set", typ.name, s.name, def.typ)
+				case isObject:
+					sub := walk(child, s.selections, depth+1)
+					fieldCost.add(sub)
+					fieldCost.depth = 1 + sub.depth
+				}
+				cost.add(fieldCost)
+			case *fragmentSpread:
+				checkDirectives(s.directives)
+				frag, ok := e.doc.fragments[s.name]
+				if !ok {
+					add(s.loc, "unknown fragment %q", s.name)
+					continue
+				}
+				fragType, ok := e.schema.types[frag.typeCond]
+				if !ok {
+					add(frag.loc, "unknown type %q", frag.typeCond)
+					continue
+				}
+				if fragType != typ {
+					add(s.loc, "fragment %q on %s cannot be spread on type %s", s.name, frag.typeCond, typ.name)
+					continue
+				}
+				info, ok := fragments[s.name]
+				if !ok {
+					info = &fragmentInfo{validating: true}
+					fragments[s.name] = info
+					info.cost = walk(fragType, frag.selections, 1)
+					info.validating = false
+				} else if info.validating {
+					add(s.loc, "fragment %q spreads itself", s.name)
+					continue
+				}
+				if depth-1+info.cost.depth > e.maxDepth {
+					add(s.loc, "query is nested deeper than %d levels", e.maxDepth)
+					continue
+				}
+				cost.add(info.cost)
+			case *inlineFragment:
+				checkDirectives(s.directives)
+				if s.typeCond != "" && s.typeCond != typ.name {
+					add(s.loc, "fragment on %s cannot be spread on type %s", s.typeCond, typ.name)
+					continue
+				}
+				cost.add(walk(typ, s.selections, depth))
+			}
+		}
+		return cost
+	}
+
+	root := e.schema.query
+	if op.kind != "query" {
+		add(op.loc, "only queries are supported, not %ss", op.kind)
+		return errs
+	}
+	checkDirectives(op.directives)
+	cost := walk(root, op.selections, 1)
+	if cost.fields > e.maxFields {
+		add(op.loc, "query selects more than %d fields", e.maxFields)
+	}
+	if cost.aliases > e.maxAliases {
+		add(op.loc, "query uses more than %d aliases", e.maxAliases)
+	}
+	return errs
+}
+
+// maxCost caps selection costs so fragments spread many times over do
+// not overflow the counts.
+const maxCost = 1 << 30
+
+// selectionCost is what a selection set adds to a query with its
+// fragments expanded.
+type selectionCost struct {
+	fields  int // fields selected
+	aliases int // fields selected under an alias
+	depth   int // levels of fields, 1 for a selection of scalars
+}
+
+// add counts o into c; depth is the deeper of the two.
+func (c *selectionCost) add(o selectionCost) {
+	c.fields = min(c.fields+o.fields, maxCost)
+	c.aliases = min(c.aliases+o.aliases, maxCost)
+	c.depth = max(c.depth, o.depth)
+}
+
+// fragmentInfo is a validated fragment; validating is set while its
+// selections are walked, so a fragment that spreads itself is caught.
+type fragmentInfo struct {
+	cost       selectionCost
+	validating bool
+}
+
+// fieldGroup is the fields of a selection set returned under one key;
+// fields repeated under the same key are merged.
+type fieldGroup struct {
+	key    string
+	fields []*field
+}
+
+// included evaluates @skip and @include.
+func (e *executor) included(dirs []*directive) bool {
+	for _, d := range dirs {
+		cond, _ := d.args[0].value.resolve(e.vars).(bool)
+		if d.name == "skip" && cond || d.name == "include" && !cond {
+			return false
+		}
+	}
+	return true
+}
+
+func (e *executor) collectFields(typ *objectType, sels []selection, groups []*fieldGroup, visited map[string]bool) []*fieldGroup {
+	for _, sel := range sels {
+		switch s := sel.(type) {
+		case *field:
+			if !e.included(s.directives) {
+				continue
+			}
+			found := false
+			for _, g := range groups {
+				if g.key == s.key() {
+					g.fields = append(g.fields, s)
+					found = true
+					break
+				}
+			}
+			if !found {
+				groups = append(groups, &fieldGroup{key: s.key(), fields: []*field{s}})
+			}
+		case *fragmentSpread:
+			if !e.included(s.directives) || visited[s.name] {
+				continue
+			}
+			visited[s.name] = true
+			groups = e.collectFields(typ, e.doc.fragments[s.name].selections, groups, visited)
+		case *inlineFragment:
+			if !e.included(s.directives) {
+				continue
+			}
+			groups = e.collectFields(typ, s.selections, groups, visited)
+		}
+	}
+	return groups
+}
+
+// coerceArgs resolves a field's arguments and applies defaults.
+func (e *executor) coerceArgs(def *fieldDef, f *field) (map[string]interface{}, error) {
+	args := make(map[string]interface{}, len(def.args))
+	for name, a := range def.args {
+		var v interface{} = a.def
+		for _, given := range f.args {
+			if given.name == name {
+				if given.value.kind != valueVariable {
+					v = given.value.resolve(e.vars)
+				} else if vv, ok := e.vars[given.value.raw]; ok {
+					v = vv
+				}
+			}
+		}
+		c, err := coerceInput(a.typ, v)
+		if err != nil {
+			return nil, fmt.Errorf("argument %q: %w", name, err)
+		}
+		args[name] = c
+	}
+	return args, nil
+}
+
+// execute resolves a selection set for every parent and returns one result
+// object per parent. Each field is resolved once for all parents.
+func (e *executor) execute(typ *objectType, parents []interface{}, sels []selection, path []interface{}) []*object {
+	results := make([]*object, len(parents))
+	for i := range results {
+		results[i] = newObject()
+	}
+	for _, g := range e.collectFields(typ, sels, nil, map[string]bool{}) {
+		f := g.fields[0]
+		if f.name == "__typename" {
+			for _, o := range results {
+				o.set(g.key, typ.name)
+			}
+			continue
+		}
+		def := typ.fields[f.name]
+		fieldPath := append(path[:len(path):len(path)], g.key)
+
+		args, err := e.coerceArgs(def, f)
+		var values []interface{}
+		if err == nil {
+			values, err = def.resolve(e.req, parents, args)
+		}
+		if err != nil {
+			e.fail(f.loc, fieldPath, "%s", err.Error())
+			for _, o := range results {
+				o.set(g.key, nil)
+			}
+			continue
+		}
+
+		child, isObject := e.schema.types[namedType(def.typ)]
+		if !isObject {
+			for i, o := range results {
+				o.set(g.key, values[i])
+			}
+			continue
+		}
+
+		// Resolve the objects of every parent together.
+		var sub []selection
+		for _, f := range g.fields {
+			sub = append(sub, f.selections...)
+		}
+		var children []interface{}
+		for _, v := range values {
+			if list, ok := v.([]interface{}); ok {
+				children = append(children, list...)
+			} else if v != nil {
+				children = append(children, v)
+			}
+		}
+		resolved := e.execute(child, children, sub, fieldPath)
+		next := 0
+		for i, v := range values {
+			switch v := v.(type) {
+			case nil:
+				results[i].set(g.key, nil)
+			case []interface{}:
+				list := make([]*object, len(v))
+				copy(list, resolved[next:next+len(v)])
+				next += len(v)
+				results[i].set(g.key, list)
+			default:
+				results[i].set(g.key, resolved[next])
+				next++
+			}
+		}
+	}
+	return results
+}
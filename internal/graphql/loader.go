@@ -0,0 +1,388 @@
+package graphql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/services"
+	"github.com/goatkit/goatflow/internal/storage"
+)
+
+type ticket struct {
+	id             int64
+	number         string
+	title          string
+	queueID        int
+	state          string
+	stateType      string
+	priorityID     int
+	priority       string
+	ticketType     string
+	lock           string
+	ownerID        int
+	responsibleID  int
+	customerID     string
+	customerUserID string
+	createTime     time.Time
+	changeTime     time.Time
+}
+
+type article struct {
+	id          int64
+	ticketID    int64
+	from        string
+	to          string
+	cc          string
+	subject     string
+	body        string
+	contentType string
+	senderType  string
+	channel     string
+	visible     bool
+	createTime  time.Time
+	createBy    int
+}
+
+type queue struct {
+	id      int
+	name    string
+	comment string
+	validID int
+}
+
+type user struct {
+	id        int
+	login     string
+	firstName string
+	lastName  string
+	validID   int
+}
+
+type dynamicField struct {
+	id         int
+	name       string
+	label      string
+	fieldType  string
+	objectType string
+}
+
+// dynamicFieldValue is the value of one dynamic field on a ticket or
+// article; multiselect fields have several values.
+type dynamicFieldValue struct {
+	field  *dynamicField
+	values []string
+}
+
+// ticketConnection is one page of a ticket list.
+type ticketConnection struct {
+	tickets   []*ticket
+	hasNext   bool
+	endCursor string
+	// where and args select every ticket of the list, for totalCount.
+	where string
+	args  []interface{}
+}
+
+// request holds the caller and the per-request caches that resolvers load
+// related records through. Records requested by several parents, such as
+// the owner shared by many tickets, are loaded once.
+type request struct {
+	ctx    context.Context
+	db     *sql.DB
+	userID int
+	token  *models.APIToken
+
+	queuePerms map[int]string
+	queues     map[int]*queue
+	users      map[int]*user
+}
+
+// requireScope fails when the caller's API token lacks scope. Session and
+// JWT callers are not limited by scopes.
+func (r *request) requireScope(scope string) error {
+	if r.token != nil && !r.token.HasScope(scope) {
+		return fmt.Errorf("token missing required scope: %s", scope)
+	}
+	return nil
+}
+
+// readableQueues returns the queues the caller may read, with their
+// permission (ro or rw).
+func (r *request) readableQueues() (map[int]string, error) {
+	if r.queuePerms != nil {
+		return r.queuePerms, nil
+	}
+	perms, err := services.NewPermissionService(r.db).GetUserQueuePermissions(r.userID)
+	if err != nil {
+		return nil, err
+	}
+	r.queuePerms = map[int]string{}
+	for id, perm := range perms {
+		if perm == "ro" || perm == "rw" {
+			r.queuePerms[id] = perm
+		}
+	}
+	return r.queuePerms, nil
+}
+
+// readableQueueIDs returns the readable queue IDs in order, as query args.
+func (r *request) readableQueueIDs() ([]interface{}, error) {
+	perms, err := r.readableQueues()
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]int, 0, len(perms))
+	for id := range perms {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	return args, nil
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?,", n), ",")
+}
+
+// missing returns the IDs not in cache yet, without duplicates.
+func missing[T any](ids []int, cache map[int]T) []interface{} {
+	seen := map[int]bool{}
+	var out []interface{}
+	for _, id := range ids {
+		if _, ok := cache[id]; !ok && !seen[id] {
+			seen[id] = true
+			out = append(out, id)
+		}
+	}
+	return out
+}
+
+// loadQueues loads queues by ID into the request cache.
+func (r *request) loadQueues(ids []int) (map[int]*queue, error) {
+	if r.queues == nil {
+		r.queues = map[int]*queue{}
+	}
+	if load := missing(ids, r.queues); len(load) > 0 {
+		rows, err := r.db.QueryContext(r.ctx, database.ConvertPlaceholders(
+			"SELECT id, name, COALESCE(comments, ''), valid_id FROM queue WHERE id IN ("+placeholders(len(load))+")"), load...)
+		if err != nil {
+			return nil, fmt.Errorf("load queues: %w", err)
+		}
+		defer rows.Close()
+		queues, err := database.CollectRows(rows, func(rows *sql.Rows) (*queue, error) {
+			var q queue
+			return &q, rows.Scan(&q.id, &q.name, &q.comment, &q.validID)
+		})
+		if err != nil {
+			return nil, fmt.Errorf("load queues: %w", err)
+		}
+		for _, q := range queues {
+			r.queues[q.id] = q
+		}
+	}
+	return r.queues, nil
+}
+
+// loadUsers loads agents by ID into the request cache.
+func (r *request) loadUsers(ids []int) (map[int]*user, error) {
+	if r.users == nil {
+		r.users = map[int]*user{}
+	}
+	if load := missing(ids, r.users); len(load) > 0 {
+		rows, err := r.db.QueryContext(r.ctx, database.ConvertPlaceholders(
+			"SELECT id, login, first_name, last_name, valid_id FROM users WHERE id IN ("+placeholders(len(load))+")"), load...)
+		if err != nil {
+			return nil, fmt.Errorf("load users: %w", err)
+		}
+		defer rows.Close()
+		users, err := database.CollectRows(rows, scanUser)
+		if err != nil {
+			return nil, fmt.Errorf("load users: %w", err)
+		}
+		for _, u := range users {
+			r.users[u.id] = u
+		}
+	}
+	return r.users, nil
+}
+
+func scanUser(rows *sql.Rows) (*user, error) {
+	var u user
+	return &u, rows.Scan(&u.id, &u.login, &u.firstName, &u.lastName, &u.validID)
+}
+
+const ticketSelect = `
+	SELECT t.id, t.tn, COALESCE(t.title, ''), t.queue_id, ts.name, tst.name, t.ticket_priority_id, tp.name,
+	       COALESCE(tt.name, ''), tl.name, t.user_id, t.responsible_user_id,
+	       COALESCE(t.customer_id, ''), COALESCE(t.customer_user_id, ''), t.create_time, t.change_time
+	FROM ticket t
+	JOIN ticket_state ts ON ts.id = t.ticket_state_id
+	JOIN ticket_state_type tst ON tst.id = ts.type_id
+	JOIN ticket_priority tp ON tp.id = t.ticket_priority_id
+	JOIN ticket_lock_type tl ON tl.id = t.ticket_lock_id
+	LEFT JOIN ticket_type tt ON tt.id = t.type_id`
+
+func scanTicket(rows *sql.Rows) (*ticket, error) {
+	var t ticket
+	return &t, rows.Scan(&t.id, &t.number, &t.title, &t.queueID, &t.state, &t.stateType, &t.priorityID, &t.priority,
+		&t.ticketType, &t.lock, &t.ownerID, &t.responsibleID, &t.customerID, &t.customerUserID, &t.createTime, &t.changeTime)
+}
+
+// queryTickets loads the tickets matching where, limited to the caller's
+// readable queues.
+func (r *request) queryTickets(where string, args []interface{}, suffix string) ([]*ticket, error) {
+	queueIDs, err := r.readableQueueIDs()
+	if err != nil {
+		return nil, err
+	}
+	if len(queueIDs) == 0 {
+		return []*ticket{}, nil
+	}
+	query := ticketSelect + " WHERE t.queue_id IN (" + placeholders(len(queueIDs)) + ")"
+	if where != "" {
+		query += " AND " + where
+	}
+	rows, err := r.db.QueryContext(r.ctx, database.ConvertPlaceholders(query+suffix), append(queueIDs, args...)...)
+	if err != nil {
+		return nil, fmt.Errorf("load tickets: %w", err)
+	}
+	defer rows.Close()
+	tickets, err := database.CollectRows(rows, scanTicket)
+	if err != nil {
+		return nil, fmt.Errorf("load tickets: %w", err)
+	}
+	return tickets, nil
+}
+
+// countTickets counts the tickets matching where in readable queues.
+func (r *request) countTickets(where string, args []interface{}) (int, error) {
+	queueIDs, err := r.readableQueueIDs()
+	if err != nil || len(queueIDs) == 0 {
+		return 0, err
+	}
+	query := "SELECT COUNT(*) FROM ticket t JOIN ticket_state ts ON ts.id = t.ticket_state_id" +
+		" JOIN ticket_state_type tst ON tst.id = ts.type_id WHERE t.queue_id IN (" + placeholders(len(queueIDs)) + ")"
+	if where != "" {
+		query += " AND " + where
+	}
+	var n int
+	if err := r.db.QueryRowContext(r.ctx, database.ConvertPlaceholders(query), append(queueIDs, args...)...).Scan(&n); err != nil {
+		return 0, fmt.Errorf("count tickets: %w", err)
+	}
+	return n, nil
+}
+
+// loadArticles loads the articles of several tickets, oldest first, keyed
+// by ticket ID.
+func (r *request) loadArticles(ticketIDs []interface{}, visibleOnly bool) (map[int64][]*article, error) {
+	byTicket := map[int64][]*article{}
+	if len(ticketIDs) == 0 {
+		return byTicket, nil
+	}
+	query := `
+		SELECT a.id, a.ticket_id, COALESCE(adm.a_from, ''), COALESCE(adm.a_to, ''), COALESCE(adm.a_cc, ''),
+		       COALESCE(adm.a_subject, ''), adm.a_body, COALESCE(adm.a_content_type, ''),
+		       COALESCE(ast.name, ''), COALESCE(cc.name, ''), a.is_visible_for_customer, a.create_time, a.create_by
+		FROM article a
+		LEFT JOIN article_data_mime adm ON adm.article_id = a.id
+		LEFT JOIN article_sender_type ast ON ast.id = a.article_sender_type_id
+		LEFT JOIN communication_channel cc ON cc.id = a.communication_channel_id
+		WHERE a.ticket_id IN (` + placeholders(len(ticketIDs)) + `)`
+	if visibleOnly {
+		query += " AND a.is_visible_for_customer = 1"
+	}
+	rows, err := r.db.QueryContext(r.ctx, database.ConvertPlaceholders(query+" ORDER BY a.ticket_id, a.id"), ticketIDs...)
+	if err != nil {
+		return nil, fmt.Errorf("load articles: %w", err)
+	}
+	defer rows.Close()
+	articles, err := database.CollectRows(rows, func(rows *sql.Rows) (*article, error) {
+		var a article
+		var body []byte
+		var visible int
+		err := rows.Scan(&a.id, &a.ticketID, &a.from, &a.to, &a.cc, &a.subject, &body, &a.contentType,
+			&a.senderType, &a.channel, &visible, &a.createTime, &a.createBy)
+		a.body = storage.DecodeText(string(body))
+		a.visible = visible == 1
+		return &a, err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("load articles: %w", err)
+	}
+	for _, a := range articles {
+		byTicket[a.ticketID] = append(byTicket[a.ticketID], a)
+	}
+	return byTicket, nil
+}
+
+// loadDynamicFieldValues loads the values of valid dynamic fields for
+// several tickets or articles, keyed by object ID, in field order. names
+// limits the fields when not empty.
+func (r *request) loadDynamicFieldValues(objectType string, objectIDs []interface{}, names []string) (map[int64][]*dynamicFieldValue, error) {
+	byObject := map[int64][]*dynamicFieldValue{}
+	if len(objectIDs) == 0 {
+		return byObject, nil
+	}
+	query := `
+		SELECT v.object_id, f.id, f.name, f.label, f.field_type, f.object_type, v.value_text, v.value_date, v.value_int
+		FROM dynamic_field_value v
+		JOIN dynamic_field f ON f.id = v.field_id
+		WHERE f.object_type = ? AND f.valid_id = 1 AND v.object_id IN (` + placeholders(len(objectIDs)) + `)`
+	args := append([]interface{}{objectType}, objectIDs...)
+	if len(names) > 0 {
+		query += " AND f.name IN (" + placeholders(len(names)) + ")"
+		for _, n := range names {
+			args = append(args, n)
+		}
+	}
+	rows, err := r.db.QueryContext(r.ctx, database.ConvertPlaceholders(query+" ORDER BY v.object_id, f.field_order, f.id, v.id"), args...)
+	if err != nil {
+		return nil, fmt.Errorf("load dynamic field values: %w", err)
+	}
+	defer rows.Close()
+
+	fields := map[int]*dynamicField{}
+	for rows.Next() {
+		var objectID int64
+		var f dynamicField
+		var text sql.NullString
+		var date sql.NullTime
+		var num sql.NullInt64
+		if err := rows.Scan(&objectID, &f.id, &f.name, &f.label, &f.fieldType, &f.objectType, &text, &date, &num); err != nil {
+			return nil, fmt.Errorf("scan dynamic field value: %w", err)
+		}
+		if known, ok := fields[f.id]; ok {
+			f = *known
+		} else {
+			fields[f.id] = &f
+		}
+		var v string
+		switch {
+		case text.Valid:
+			v = text.String
+		case date.Valid:
+			v = date.Time.Format(time.RFC3339)
+		case num.Valid:
+			v = strconv.FormatInt(num.Int64, 10)
+		default:
+			continue
+		}
+		values := byObject[objectID]
+		if n := len(values); n > 0 && values[n-1].field.id == f.id {
+			values[n-1].values = append(values[n-1].values, v)
+			continue
+		}
+		byObject[objectID] = append(values, &dynamicFieldValue{field: fields[f.id], values: []string{v}})
+	}
+	return byObject, rows.Err()
+}
@@ -0,0 +1,549 @@
+package graphql
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goatkit/goatflow/internal/database"
+)
+
+// List limits of the schema.
+const (
+	DefaultTicketPageSize = 25
+	MaxTicketPageSize     = 100
+	MaxArticles           = 100
+	MaxUsers              = 200
+)
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+// fieldOf builds a field whose value is read from each parent.
+func fieldOf[T any](typ string, get func(T) interface{}) *fieldDef {
+	return &fieldDef{typ: typ, resolve: func(_ *request, parents []interface{}, _ map[string]interface{}) ([]interface{}, error) {
+		out := make([]interface{}, len(parents))
+		for i, p := range parents {
+			out[i] = get(p.(T))
+		}
+		return out, nil
+	}}
+}
+
+// userRef builds a User field loading the referenced agents in one query.
+func userRef[T any](id func(T) int) *fieldDef {
+	return &fieldDef{typ: "User", resolve: func(r *request, parents []interface{}, _ map[string]interface{}) ([]interface{}, error) {
+		ids := make([]int, len(parents))
+		for i, p := range parents {
+			ids[i] = id(p.(T))
+		}
+		users, err := r.loadUsers(ids)
+		if err != nil {
+			return nil, err
+		}
+		out := make([]interface{}, len(parents))
+		for i, id := range ids {
+			if u, ok := users[id]; ok {
+				out[i] = u
+			}
+		}
+		return out, nil
+	}}
+}
+
+// dynamicFieldsOf builds the dynamicFields field of tickets or articles.
+func dynamicFieldsOf[T any](objectType string, id func(T) int64) *fieldDef {
+	return &fieldDef{
+		typ:  "[DynamicFieldValue!]!",
+		args: map[string]argDef{"names": {typ: "[String!]"}},
+		resolve: func(r *request, parents []interface{}, args map[string]interface{}) ([]interface{}, error) {
+			ids := make([]interface{}, len(parents))
+			for i, p := range parents {
+				ids[i] = id(p.(T))
+			}
+			values, err := r.loadDynamicFieldValues(objectType, ids, stringList(args["names"]))
+			if err != nil {
+				return nil, err
+			}
+			out := make([]interface{}, len(parents))
+			for i, p := range parents {
+				list := []interface{}{}
+				for _, v := range values[id(p.(T))] {
+					list = append(list, v)
+				}
+				out[i] = list
+			}
+			return out, nil
+		},
+	}
+}
+
+func stringList(v interface{}) []string {
+	items, _ := v.([]interface{})
+	out := make([]string, 0, len(items))
+	for _, item := range items {
+		if s, ok := item.(string); ok {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// rootField builds a Query field, which has a single parent.
+func rootField(typ string, args map[string]argDef, fn func(r *request, args map[string]interface{}) (interface{}, error)) *fieldDef {
+	return &fieldDef{typ: typ, args: args, resolve: func(r *request, _ []interface{}, args map[string]interface{}) ([]interface{}, error) {
+		v, err := fn(r, args)
+		if err != nil {
+			return nil, err
+		}
+		return []interface{}{v}, nil
+	}}
+}
+
+func formatTime(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return t.Format(time.RFC3339)
+}
+
+func encodeTicketCursor(id int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte("ticket:" + strconv.FormatInt(id, 10)))
+}
+
+func decodeTicketCursor(cursor string) (int64, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || !strings.HasPrefix(string(raw), "ticket:") {
+		return 0, errInvalidCursor
+	}
+	id, err := strconv.ParseInt(strings.TrimPrefix(string(raw), "ticket:"), 10, 64)
+	if err != nil || id <= 0 {
+		return 0, errInvalidCursor
+	}
+	return id, nil
+}
+
+func newSchema() *schema {
+	ticketType := &objectType{name: "Ticket", fields: map[string]*fieldDef{
+		"id":             fieldOf("Int!", func(t *ticket) interface{} { return t.id }),
+		"number":         fieldOf("String!", func(t *ticket) interface{} { return t.number }),
+		"title":          fieldOf("String!", func(t *ticket) interface{} { return t.title }),
+		"queueId":        fieldOf("Int!", func(t *ticket) interface{} { return t.queueID }),
+		"state":          fieldOf("String!", func(t *ticket) interface{} { return t.state }),
+		"stateType":      fieldOf("String!", func(t *ticket) interface{} { return t.stateType }),
+		"priorityId":     fieldOf("Int!", func(t *ticket) interface{} { return t.priorityID }),
+		"priority":       fieldOf("String!", func(t *ticket) interface{} { return t.priority }),
+		"type":           fieldOf("String", func(t *ticket) interface{} { return nullIfEmpty(t.ticketType) }),
+		"lock":           fieldOf("String!", func(t *ticket) interface{} { return t.lock }),
+		"customerId":     fieldOf("String", func(t *ticket) interface{} { return nullIfEmpty(t.customerID) }),
+		"customerUserId": fieldOf("String", func(t *ticket) interface{} { return nullIfEmpty(t.customerUserID) }),
+		"createTime":     fieldOf("DateTime!", func(t *ticket) interface{} { return formatTime(t.createTime) }),
+		"changeTime":     fieldOf("DateTime!", func(t *ticket) interface{} { return formatTime(t.changeTime) }),
+		"owner":          userRef(func(t *ticket) int { return t.ownerID }),
+		"responsible":    userRef(func(t *ticket) int { return t.responsibleID }),
+		"dynamicFields":  dynamicFieldsOf("Ticket", func(t *ticket) int64 { return t.id }),
+		"queue": {typ: "Queue", resolve: func(r *request, parents []interface{}, _ map[string]interface{}) ([]interface{}, error) {
+			ids := make([]int, len(parents))
+			for i, p := range parents {
+				ids[i] = p.(*ticket).queueID
+			}
+			queues, err := r.loadQueues(ids)
+			if err != nil {
+				return nil, err
+			}
+			out := make([]interface{}, len(parents))
+			for i, id := range ids {
+				if q, ok := queues[id]; ok {
+					out[i] = q
+				}
+			}
+			return out, nil
+		}},
+		"articles": {
+			typ: "[Article!]!",
+			args: map[string]argDef{
+				"first":              {typ: "Int"},
+				"last":               {typ: "Int"},
+				"visibleForCustomer": {typ: "Boolean"},
+			},
+			resolve: resolveTicketArticles,
+		},
+	}}
+
+	articleType := &objectType{name: "Article", fields: map[string]*fieldDef{
+		"id":                   fieldOf("Int!", func(a *article) interface{} { return a.id }),
+		"ticketId":             fieldOf("Int!", func(a *article) interface{} { return a.ticketID }),
+		"from":                 fieldOf("String!", func(a *article) interface{} { return a.from }),
+		"to":                   fieldOf("String!", func(a *article) interface{} { return a.to }),
+		"cc":                   fieldOf("String!", func(a *article) interface{} { return a.cc }),
+		"subject":              fieldOf("String!", func(a *article) interface{} { return a.subject }),
+		"body":                 fieldOf("String!", func(a *article) interface{} { return a.body }),
+		"contentType":          fieldOf("String!", func(a *article) interface{} { return a.contentType }),
+		"senderType":           fieldOf("String!", func(a *article) interface{} { return a.senderType }),
+		"channel":              fieldOf("String!", func(a *article) interface{} { return a.channel }),
+		"isVisibleForCustomer": fieldOf("Boolean!", func(a *article) interface{} { return a.visible }),
+		"createTime":           fieldOf("DateTime!", func(a *article) interface{} { return formatTime(a.createTime) }),
+		"createdBy":            userRef(func(a *article) int { return a.createBy }),
+		"dynamicFields":        dynamicFieldsOf("Article", func(a *article) int64 { return a.id }),
+	}}
+
+	queueType := &objectType{name: "Queue", fields: map[string]*fieldDef{
+		"id":      fieldOf("Int!", func(q *queue) interface{} { return q.id }),
+		"name":    fieldOf("String!", func(q *queue) interface{} { return q.name }),
+		"comment": fieldOf("String", func(q *queue) interface{} { return nullIfEmpty(q.comment) }),
+		"valid":   fieldOf("Boolean!", func(q *queue) interface{} { return q.validID == 1 }),
+		"permission": {typ: "String", resolve: func(r *request, parents []interface{}, _ map[string]interface{}) ([]interface{}, error) {
+			perms, err := r.readableQueues()
+			if err != nil {
+				return nil, err
+			}
+			out := make([]interface{}, len(parents))
+			for i, p := range parents {
+				if perm, ok := perms[p.(*queue).id]; ok {
+					out[i] = perm
+				}
+			}
+			return out, nil
+		}},
+	}}
+
+	userType := &objectType{name: "User", fields: map[string]*fieldDef{
+		"id":        fieldOf("Int!", func(u *user) interface{} { return u.id }),
+		"login":     fieldOf("String!", func(u *user) interface{} { return u.login }),
+		"firstName": fieldOf("String!", func(u *user) interface{} { return u.firstName }),
+		"lastName":  fieldOf("String!", func(u *user) interface{} { return u.lastName }),
+		"fullName": fieldOf("String!", func(u *user) interface{} {
+			return strings.TrimSpace(u.firstName + " " + u.lastName)
+		}),
+		"valid": fieldOf("Boolean!", func(u *user) interface{} { return u.validID == 1 }),
+	}}
+
+	dynamicFieldType := &objectType{name: "DynamicField", fields: map[string]*fieldDef{
+		"id":         fieldOf("Int!", func(f *dynamicField) interface{} { return f.id }),
+		"name":       fieldOf("String!", func(f *dynamicField) interface{} { return f.name }),
+		"label":      fieldOf("String!", func(f *dynamicField) interface{} { return f.label }),
+		"fieldType":  fieldOf("String!", func(f *dynamicField) interface{} { return f.fieldType }),
+		"objectType": fieldOf("String!", func(f *dynamicField) interface{} { return f.objectType }),
+	}}
+
+	dynamicFieldValueType := &objectType{name: "DynamicFieldValue", fields: map[string]*fieldDef{
+		"name":      fieldOf("String!", func(v *dynamicFieldValue) interface{} { return v.field.name }),
+		"label":     fieldOf("String!", func(v *dynamicFieldValue) interface{} { return v.field.label }),
+		"fieldType": fieldOf("String!", func(v *dynamicFieldValue) interface{} { return v.field.fieldType }),
+		"value":     fieldOf("String", func(v *dynamicFieldValue) interface{} { return v.values[0] }),
+		"values":    fieldOf("[String!]!", func(v *dynamicFieldValue) interface{} { return v.values }),
+	}}
+
+	connectionType := &objectType{name: "TicketConnection", fields: map[string]*fieldDef{
+		"nodes": fieldOf("[Ticket!]!", func(c *ticketConnection) interface{} {
+			list := make([]interface{}, len(c.tickets))
+			for i, t := range c.tickets {
+				list[i] = t
+			}
+			return list
+		}),
+		"pageInfo": fieldOf("PageInfo!", func(c *ticketConnection) interface{} { return c }),
+		"totalCount": {typ: "Int!", resolve: func(r *request, parents []interface{}, _ map[string]interface{}) ([]interface{}, error) {
+			out := make([]interface{}, len(parents))
+			for i, p := range parents {
+				c := p.(*ticketConnection)
+				n, err := r.countTickets(c.where, c.args)
+				if err != nil {
+					return nil, err
+				}
+				out[i] = n
+			}
+			return out, nil
+		}},
+	}}
+
+	pageInfoType := &objectType{name: "PageInfo", fields: map[string]*fieldDef{
+		"hasNextPage": fieldOf("Boolean!", func(c *ticketConnection) interface{} { return c.hasNext }),
+		"endCursor":   fieldOf("String", func(c *ticketConnection) interface{} { return nullIfEmpty(c.endCursor) }),
+	}}
+
+	queryType := &objectType{name: "Query", fields: map[string]*fieldDef{
+		"me": rootField("User", nil, func(r *request, _ map[string]interface{}) (interface{}, error) {
+			users, err := r.loadUsers([]int{r.userID})
+			if err != nil || users[r.userID] == nil {
+				return nil, err
+			}
+			return users[r.userID], nil
+		}),
+		"ticket": rootField("Ticket", map[string]argDef{"id": {typ: "Int"}, "number": {typ: "String"}}, resolveTicket),
+		"tickets": rootField("TicketConnection!", map[string]argDef{
+			"first":          {typ: "Int", def: DefaultTicketPageSize},
+			"after":          {typ: "String"},
+			"queueId":        {typ: "Int"},
+			"state":          {typ: "String"},
+			"stateType":      {typ: "String"},
+			"customerId":     {typ: "String"},
+			"customerUserId": {typ: "String"},
+			"ownerId":        {typ: "Int"},
+			"responsibleId":  {typ: "Int"},
+		}, resolveTickets),
+		"queue": rootField("Queue", map[string]argDef{"id": {typ: "Int!"}}, func(r *request, args map[string]interface{}) (interface{}, error) {
+			if err := r.requireScope("queues:read"); err != nil {
+				return nil, err
+			}
+			perms, err := r.readableQueues()
+			if err != nil {
+				return nil, err
+			}
+			id := args["id"].(int)
+			if _, ok := perms[id]; !ok {
+				return nil, nil
+			}
+			queues, err := r.loadQueues([]int{id})
+			if err != nil || queues[id] == nil {
+				return nil, err
+			}
+			return queues[id], nil
+		}),
+		"queues": rootField("[Queue!]!", nil, func(r *request, _ map[string]interface{}) (interface{}, error) {
+			if err := r.requireScope("queues:read"); err != nil {
+				return nil, err
+			}
+			perms, err := r.readableQueues()
+			if err != nil {
+				return nil, err
+			}
+			ids := make([]int, 0, len(perms))
+			for id := range perms {
+				ids = append(ids, id)
+			}
+			queues, err := r.loadQueues(ids)
+			if err != nil {
+				return nil, err
+			}
+			list := make([]*queue, 0, len(ids))
+			for _, id := range ids {
+				if q, ok := queues[id]; ok {
+					list = append(list, q)
+				}
+			}
+			sort.Slice(list, func(i, j int) bool { return list[i].name < list[j].name })
+			out := make([]interface{}, len(list))
+			for i, q := range list {
+				out[i] = q
+			}
+			return out, nil
+		}),
+		"user": rootField("User", map[string]argDef{"id": {typ: "Int!"}}, func(r *request, args map[string]interface{}) (interface{}, error) {
+			if err := r.requireScope("users:read"); err != nil {
+				return nil, err
+			}
+			id := args["id"].(int)
+			users, err := r.loadUsers([]int{id})
+			if err != nil || users[id] == nil {
+				return nil, err
+			}
+			return users[id], nil
+		}),
+		"users": rootField("[User!]!", map[string]argDef{
+			"search": {typ: "String"},
+			"first":  {typ: "Int", def: 50},
+			"offset": {typ: "Int", def: 0},
+		}, resolveUsers),
+		"dynamicFields": rootField("[DynamicField!]!", map[string]argDef{"objectType": {typ: "String"}}, resolveDynamicFields),
+	}}
+
+	types := map[string]*objectType{}
+	for _, t := range []*objectType{queryType, ticketType, articleType, queueType, userType, dynamicFieldType,
+		dynamicFieldValueType, connectionType, pageInfoType} {
+		types[t.name] = t
+	}
+	return &schema{query: queryType, types: types}
+}
+
+func nullIfEmpty(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+func resolveTicket(r *request, args map[string]interface{}) (interface{}, error) {
+	if err := r.requireScope("tickets:read"); err != nil {
+		return nil, err
+	}
+	var where string
+	var arg interface{}
+	switch {
+	case args["id"] != nil:
+		where, arg = "t.id = ?", args["id"]
+	case args["number"] != nil:
+		where, arg = "t.tn = ?", args["number"]
+	default:
+		return nil, errors.New("ticket requires id or number")
+	}
+	tickets, err := r.queryTickets(where, []interface{}{arg}, "")
+	if err != nil || len(tickets) == 0 {
+		// Tickets in queues the caller cannot read are not found either.
+		return nil, err
+	}
+	return tickets[0], nil
+}
+
+func resolveTickets(r *request, args map[string]interface{}) (interface{}, error) {
+	if err := r.requireScope("tickets:read"); err != nil {
+		return nil, err
+	}
+	first, _ := args["first"].(int)
+	if first < 1 || first > MaxTicketPageSize {
+		return nil, errors.New("first must be between 1 and " + strconv.Itoa(MaxTicketPageSize))
+	}
+
+	var where []string
+	var whereArgs []interface{}
+	filters := []struct{ arg, column string }{
+		{"queueId", "t.queue_id"},
+		{"state", "ts.name"},
+		{"stateType", "tst.name"},
+		{"customerId", "t.customer_id"},
+		{"customerUserId", "t.customer_user_id"},
+		{"ownerId", "t.user_id"},
+		{"responsibleId", "t.responsible_user_id"},
+	}
+	for _, f := range filters {
+		if v := args[f.arg]; v != nil {
+			where = append(where, f.column+" = ?")
+			whereArgs = append(whereArgs, v)
+		}
+	}
+	conn := &ticketConnection{where: strings.Join(where, " AND "), args: whereArgs}
+
+	pageWhere, pageArgs := where, whereArgs
+	if after, _ := args["after"].(string); after != "" {
+		id, err := decodeTicketCursor(after)
+		if err != nil {
+			return nil, err
+		}
+		pageWhere = append(pageWhere[:len(pageWhere):len(pageWhere)], "t.id < ?")
+		pageArgs = append(pageArgs[:len(pageArgs):len(pageArgs)], id)
+	}
+	tickets, err := r.queryTickets(strings.Join(pageWhere, " AND "), pageArgs,
+		" ORDER BY t.id DESC LIMIT "+strconv.Itoa(first+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(tickets) > first {
+		tickets, conn.hasNext = tickets[:first], true
+	}
+	if n := len(tickets); n > 0 {
+		conn.endCursor = encodeTicketCursor(tickets[n-1].id)
+	}
+	conn.tickets = tickets
+	return conn, nil
+}
+
+func resolveTicketArticles(r *request, parents []interface{}, args map[string]interface{}) ([]interface{}, error) {
+	if err := r.requireScope("articles:read"); err != nil {
+		return nil, err
+	}
+	first, hasFirst := args["first"].(int)
+	last, hasLast := args["last"].(int)
+	switch {
+	case hasFirst && hasLast:
+		return nil, errors.New("articles accepts first or last, not both")
+	case hasFirst && (first < 0 || first > MaxArticles), hasLast && (last < 0 || last > MaxArticles):
+		return nil, errors.New("first and last must be between 0 and " + strconv.Itoa(MaxArticles))
+	case !hasFirst && !hasLast:
+		first, hasFirst = MaxArticles, true
+	}
+	visibleOnly, _ := args["visibleForCustomer"].(bool)
+
+	ids := make([]interface{}, len(parents))
+	for i, p := range parents {
+		ids[i] = p.(*ticket).id
+	}
+	byTicket, err := r.loadArticles(ids, visibleOnly)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]interface{}, len(parents))
+	for i, p := range parents {
+		articles := byTicket[p.(*ticket).id]
+		if hasFirst && len(articles) > first {
+			articles = articles[:first]
+		}
+		if hasLast && len(articles) > last {
+			articles = articles[len(articles)-last:]
+		}
+		list := make([]interface{}, len(articles))
+		for j, a := range articles {
+			list[j] = a
+		}
+		out[i] = list
+	}
+	return out, nil
+}
+
+func resolveUsers(r *request, args map[string]interface{}) (interface{}, error) {
+	if err := r.requireScope("users:read"); err != nil {
+		return nil, err
+	}
+	first, _ := args["first"].(int)
+	offset, _ := args["offset"].(int)
+	if first < 1 || first > MaxUsers {
+		return nil, errors.New("first must be between 1 and " + strconv.Itoa(MaxUsers))
+	}
+	if offset < 0 {
+		return nil, errors.New("offset must not be negative")
+	}
+	query := "SELECT id, login, first_name, last_name, valid_id FROM users"
+	var qargs []interface{}
+	if search, _ := args["search"].(string); search != "" {
+		pattern := "%" + strings.ToLower(search) + "%"
+		query += " WHERE LOWER(login) LIKE ? OR LOWER(first_name) LIKE ? OR LOWER(last_name) LIKE ?"
+		qargs = append(qargs, pattern, pattern, pattern)
+	}
+	query += " ORDER BY id LIMIT " + strconv.Itoa(first) + " OFFSET " + strconv.Itoa(offset)
+	rows, err := r.db.QueryContext(r.ctx, database.ConvertPlaceholders(query), qargs...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	users, err := database.CollectRows(rows, scanUser)
+	if err != nil {
+		return nil, err
+	}
+	if r.users == nil {
+		r.users = map[int]*user{}
+	}
+	out := make([]interface{}, len(users))
+	for i, u := range users {
+		r.users[u.id] = u
+		out[i] = u
+	}
+	return out, nil
+}
+
+func resolveDynamicFields(r *request, args map[string]interface{}) (interface{}, error) {
+	query := "SELECT id, name, label, field_type, object_type FROM dynamic_field WHERE valid_id = 1"
+	var qargs []interface{}
+	if objectType, _ := args["objectType"].(string); objectType != "" {
+		query += " AND object_type = ?"
+		qargs = append(qargs, objectType)
+	}
+	rows, err := r.db.QueryContext(r.ctx, database.ConvertPlaceholders(query+" ORDER BY object_type, field_order, id"), qargs...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	fields, err := database.CollectRows(rows, func(rows *sql.Rows) (*dynamicField, error) {
+		var f dynamicField
+		return &f, rows.Scan(&f.id, &f.name, &f.label, &f.fieldType, &f.objectType)
+	})
+	if err != nil {
+		return nil, err
+	}
+	out := make([]interface{}, len(fields))
+	for i, f := range fields {
+		out[i] = f
+	}
+	return out, nil
+}
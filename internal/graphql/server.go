@@ -0,0 +1,114 @@
+// Package graphql implements the read-only GraphQL API over tickets,
+// articles, queues, users and dynamic fields.
+//
+// Fields are resolved breadth first: each field of a selection set is
+// resolved once for all parent objects, so the owners of a page of tickets
+// load in one query rather than one per ticket, and records referenced
+// repeatedly are cached for the request.
+package graphql
+
+import (
+	"context"
+	"database/sql"
+	_ "embed"
+	"errors"
+
+	"github.com/goatkit/goatflow/internal/models"
+)
+
+// Default query limits.
+const (
+	DefaultMaxDepth   = 10  // how deeply queries may nest fields
+	DefaultMaxFields  = 500 // fields a query may select, with fragments expanded
+	DefaultMaxAliases = 50  // aliased fields a query may select
+)
+
+// SchemaSDL is the schema in the GraphQL schema definition language.
+//
+//go:embed schema.graphql
+var SchemaSDL string
+
+var goatflowSchema = newSchema()
+
+// Params is a GraphQL request as sent over HTTP.
+type Params struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// Options configures a Server.
+type Options struct {
+	// MaxDepth limits field nesting; 0 means DefaultMaxDepth.
+	MaxDepth int
+	// MaxFields limits the fields a query selects once its fragments are
+	// expanded; 0 means DefaultMaxFields.
+	MaxFields int
+	// MaxAliases limits the aliased fields a query selects; 0 means
+	// DefaultMaxAliases.
+	MaxAliases int
+}
+
+// Server executes GraphQL queries on behalf of one agent. Results are
+// limited to what the agent may read, and to the scopes of token when the
+// request was authenticated by an API token.
+type Server struct {
+	db     *sql.DB
+	userID int
+	token  *models.APIToken
+	opts   Options
+}
+
+// NewServer creates a server for the agent userID. token is nil for
+// session and JWT authentication.
+func NewServer(db *sql.DB, userID int, token *models.APIToken, opts Options) *Server {
+	if opts.MaxDepth <= 0 {
+		opts.MaxDepth = DefaultMaxDepth
+	}
+	if opts.MaxFields <= 0 {
+		opts.MaxFields = DefaultMaxFields
+	}
+	if opts.MaxAliases <= 0 {
+		opts.MaxAliases = DefaultMaxAliases
+	}
+	return &Server{db: db, userID: userID, token: token, opts: opts}
+}
+
+// Execute runs a query. Errors in the request itself are returned without
+// data; errors resolving fields are returned next to the partial data.
+func (s *Server) Execute(ctx context.Context, p Params) *Response {
+	if p.Query == "" {
+		return requestError("query is required")
+	}
+	doc, err := parseDocument(p.Query)
+	if err != nil {
+		var syntaxErr *SyntaxError
+		if errors.As(err, &syntaxErr) {
+			return &Response{Errors: []*Error{{Message: "syntax error: " + syntaxErr.Message, Locations: []Location{syntaxErr.Location}}}}
+		}
+		return requestError("%s", err.Error())
+	}
+	op, err := selectOperation(doc, p.OperationName)
+	if err != nil {
+		return requestError("%s", err.Error())
+	}
+	vars, err := coerceVariables(op, p.Variables)
+	if err != nil {
+		return requestError("%s", err.Error())
+	}
+
+	e := &executor{
+		schema:     goatflowSchema,
+		doc:        doc,
+		vars:       vars,
+		maxDepth:   s.opts.MaxDepth,
+		maxFields:  s.opts.MaxFields,
+		maxAliases: s.opts.MaxAliases,
+		req:        &request{ctx: ctx, db: s.db, userID: s.userID, token: s.token},
+	}
+	if errs := e.validate(op); len(errs) > 0 {
+		return &Response{Errors: errs}
+	}
+	data := e.execute(goatflowSchema.query, []interface{}{struct{}{}}, op.selections, nil)[0]
+	return &Response{Data: data, Errors: e.errors}
+}
@@ -0,0 +1,202 @@
+package graphql
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/testutil"
+)
+
+// newTestDB creates three tickets, one of them in the Finance queue, which
+// user 1 cannot read.
+func newTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db := testutil.MigratedDB(t)
+	for _, stmt := range []string{
+		`INSERT INTO users (id, login, pw, first_name, last_name, valid_id, create_time, create_by, change_time, change_by)
+			VALUES (1, 'agent', 'x', 'Ada', 'Agent', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1),
+			       (2, 'boss', 'x', 'Bo', 'Boss', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+		`INSERT INTO groups (id, name, valid_id, create_time, create_by, change_time, change_by)
+			VALUES (10, 'support', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1),
+			       (20, 'finance', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+		`UPDATE queue SET name = 'Support', group_id = 10, comments = 'First line' WHERE id = 1`,
+		`UPDATE queue SET name = 'Finance', group_id = 20, comments = NULL WHERE id = 2`,
+		`INSERT INTO group_user (user_id, group_id, permission_key, create_time, create_by, change_time, change_by)
+			VALUES (1, 10, 'ro', CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1),
+			       (1, 20, 'create', CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+		`INSERT INTO ticket (id, tn, title, queue_id, ticket_lock_id, user_id, responsible_user_id, ticket_priority_id,
+			ticket_state_id, customer_id, customer_user_id, timeout, until_time, escalation_time, escalation_update_time,
+			escalation_response_time, escalation_solution_time, archive_flag, create_time, create_by, change_time, change_by) VALUES
+			(1, '1001', 'Printer', 1, 1, 2, 1, 3, 2, 'ACME', 'jane', 0, 0, 0, 0, 0, 0, 0, '2026-01-01 10:00:00', 1, '2026-01-01 10:00:00', 1),
+			(2, '1002', 'Salary', 2, 1, 2, 2, 3, 2, 'ACME', 'jane', 0, 0, 0, 0, 0, 0, 0, '2026-01-02 10:00:00', 1, '2026-01-02 10:00:00', 1),
+			(3, '1003', 'VPN', 1, 1, 1, 1, 3, 4, NULL, NULL, 0, 0, 0, 0, 0, 0, 0, '2026-01-03 10:00:00', 1, '2026-01-03 10:00:00', 1)`,
+		`INSERT INTO article (id, ticket_id, article_sender_type_id, communication_channel_id, is_visible_for_customer,
+			create_time, create_by, change_time, change_by) VALUES
+			(1, 1, 3, 1, 1, '2026-01-01 10:00:00', 1, '2026-01-01 10:00:00', 1),
+			(2, 1, 1, 3, 0, '2026-01-01 11:00:00', 2, '2026-01-01 11:00:00', 2)`,
+		`INSERT INTO article_data_mime (article_id, a_from, a_to, a_cc, a_subject, a_body, a_content_type, incoming_time,
+			create_time, create_by, change_time, change_by) VALUES
+			(1, 'jane@acme.example', 'support@example.com', '', 'Printer broken', 'It jams.', 'text/plain', 0,
+				CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1),
+			(2, 'boss@example.com', '', '', 'Note', 'Order a new one.', 'text/plain', 0,
+				CURRENT_TIMESTAMP, 2, CURRENT_TIMESTAMP, 2)`,
+		`INSERT INTO dynamic_field (id, name, label, field_order, field_type, object_type, valid_id,
+			create_time, create_by, change_time, change_by) VALUES
+			(1, 'Tags', 'Tags', 1, 'Multiselect', 'Ticket', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1),
+			(2, 'Old', 'Old', 2, 'Text', 'Ticket', 2, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+		`INSERT INTO dynamic_field_value (id, field_id, object_id, value_text)
+			VALUES (1, 1, 1, 'hardware'), (2, 1, 1, 'urgent'), (3, 2, 1, 'hidden')`,
+	} {
+		_, err := db.Exec(stmt)
+		require.NoError(t, err, stmt)
+	}
+	return db
+}
+
+func execute(t *testing.T, s *Server, query string, vars map[string]interface{}) (map[string]interface{}, []*Error) {
+	t.Helper()
+	resp := s.Execute(context.Background(), Params{Query: query, Variables: vars})
+	if resp.Data == nil {
+		return nil, resp.Errors
+	}
+	raw, err := json.Marshal(resp)
+	require.NoError(t, err)
+	var out struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(raw, &out))
+	return out.Data, resp.Errors
+}
+
+func TestExecute_RequestErrors(t *testing.T) {
+	s := NewServer(nil, 1, nil, Options{MaxDepth: 3})
+	for _, tc := range []struct{ query, want string }{
+		{"", "query is required"},
+		{"{ tickets { nodes { id } }", "syntax error: unexpected end of document"},
+		{"{ ticket(id: 1) { nope } }", `cannot query field "nope" on type Ticket`},
+		{"{ ticket(id: 1, queue: 2) { id } }", `unknown argument "queue"`},
+		{"{ ticket(id: 1) }", "must have a selection of subfields"},
+		{"mutation { ticket(id: 1) { id } }", "only queries are supported"},
+		{"{ __schema { types { name } } }", "introspection is not supported"},
+		{"{ tickets { nodes { articles { createdBy { login } } } } }", "nested deeper than 3 levels"},
+		{"{ tickets { nodes { ...A } } } fragment A on Ticket { articles { createdBy { login } } }", "nested deeper than 3 levels"},
+		{"query($id: Int!) { ticket(id: $id) { id } }", "variable $id of type Int! was not provided"},
+		{"{ ticket(id: $id) { id } }", "variable $id is not defined"},
+		{"{ ...F } fragment F on Query { ...F }", `fragment "F" spreads itself`},
+		{"query A { me { id } } query B { me { id } }", "operationName is required"},
+	} {
+		resp := s.Execute(context.Background(), Params{Query: tc.query})
+		require.NotEmpty(t, resp.Errors, tc.query)
+		assert.Nil(t, resp.Data, tc.query)
+		assert.Contains(t, resp.Errors[0].Message, tc.want, tc.query)
+	}
+}
+
+func TestExecute_QueryCostLimits(t *testing.T) {
+	s := NewServer(nil, 1, nil, Options{})
+
+	// Each fragment spreads the one before twice, doubling the fields.
+	var query strings.Builder
+	query.WriteString("{ ...F40 } fragment F0 on Query { me { id } }")
+	for i := 1; i <= 40; i++ {
+		fmt.Fprintf(&query, " fragment F%d on Query { ...F%d ...F%d }", i, i-1, i-1)
+	}
+	start := time.Now()
+	resp := s.Execute(context.Background(), Params{Query: query.String()})
+	assert.Less(t, time.Since(start), time.Second, "fragments must be validated once")
+	require.Len(t, resp.Errors, 1)
+	assert.Equal(t, "query selects more than 500 fields", resp.Errors[0].Message)
+	assert.Nil(t, resp.Data)
+
+	var aliases strings.Builder
+	aliases.WriteString("{")
+	for i := 0; i <= DefaultMaxAliases; i++ {
+		fmt.Fprintf(&aliases, " a%d: me { id }", i)
+	}
+	aliases.WriteString(" }")
+	resp = s.Execute(context.Background(), Params{Query: aliases.String()})
+	require.Len(t, resp.Errors, 1)
+	assert.Equal(t, "query uses more than 50 aliases", resp.Errors[0].Message)
+}
+
+func TestExecute_TicketsLimitedToReadableQueues(t *testing.T) {
+	s := NewServer(newTestDB(t), 1, nil, Options{})
+
+	data, errs := execute(t, s, `query Page($after: String) {
+		tickets(first: 1, after: $after) {
+			totalCount
+			pageInfo { hasNextPage endCursor }
+			nodes { number state queue { name permission } owner { login } }
+		}
+	}`, nil)
+	require.Empty(t, errs)
+	page := data["tickets"].(map[string]interface{})
+	assert.EqualValues(t, 2, page["totalCount"], "the Finance ticket is not readable")
+	assert.Equal(t, []interface{}{map[string]interface{}{
+		"number": "1003", "state": "closed successful",
+		"queue": map[string]interface{}{"name": "Support", "permission": "ro"},
+		"owner": map[string]interface{}{"login": "agent"},
+	}}, page["nodes"])
+	info := page["pageInfo"].(map[string]interface{})
+	assert.Equal(t, true, info["hasNextPage"])
+
+	data, errs = execute(t, s, `query Page($after: String) {
+		tickets(first: 1, after: $after) { pageInfo { hasNextPage } nodes { number } }
+	}`, map[string]interface{}{"after": info["endCursor"]})
+	require.Empty(t, errs)
+	page = data["tickets"].(map[string]interface{})
+	assert.Equal(t, []interface{}{map[string]interface{}{"number": "1001"}}, page["nodes"])
+	assert.Equal(t, false, page["pageInfo"].(map[string]interface{})["hasNextPage"])
+
+	data, errs = execute(t, s, `{ hidden: ticket(number: "1002") { id } queues { name } }`, nil)
+	require.Empty(t, errs)
+	assert.Nil(t, data["hidden"])
+	assert.Equal(t, []interface{}{map[string]interface{}{"name": "Support"}}, data["queues"])
+}
+
+func TestExecute_NestedFields(t *testing.T) {
+	s := NewServer(newTestDB(t), 1, nil, Options{})
+
+	data, errs := execute(t, s, `{
+		ticket(id: 1) {
+			...Summary
+			latest: articles(last: 1) { subject createdBy { fullName } }
+			public: articles(visibleForCustomer: true) { body channel senderType }
+			dynamicFields { name value values }
+		}
+	}
+	fragment Summary on Ticket { __typename title responsible @include(if: false) { login } }`, nil)
+	require.Empty(t, errs)
+	want := `{"ticket": {
+		"__typename": "Ticket",
+		"title": "Printer",
+		"latest": [{"subject": "Note", "createdBy": {"fullName": "Bo Boss"}}],
+		"public": [{"body": "It jams.", "channel": "Email", "senderType": "customer"}],
+		"dynamicFields": [{"name": "Tags", "value": "hardware", "values": ["hardware", "urgent"]}]
+	}}`
+	got, err := json.Marshal(data)
+	require.NoError(t, err)
+	assert.JSONEq(t, want, string(got))
+}
+
+func TestExecute_TokenScopes(t *testing.T) {
+	token := &models.APIToken{ID: 7, Scopes: []string{"tickets:read"}}
+	s := NewServer(newTestDB(t), 1, token, Options{})
+
+	data, errs := execute(t, s, `{ ticket(id: 1) { title articles { id } } users { login } }`, nil)
+	require.Len(t, errs, 2)
+	assert.Equal(t, "token missing required scope: articles:read", errs[0].Message)
+	assert.Equal(t, []interface{}{"ticket", "articles"}, errs[0].Path)
+	assert.Equal(t, []interface{}{"users"}, errs[1].Path)
+	assert.Equal(t, map[string]interface{}{"title": "Printer", "articles": nil}, data["ticket"])
+	assert.Nil(t, data["users"])
+}
@@ -0,0 +1,128 @@
+package grpcapi
+
+import (
+	"context"
+	"net"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/goatkit/goatflow/internal/models"
+)
+
+// TokenVerifier checks API tokens; service.APITokenService implements it,
+// as it does for the HTTP API.
+type TokenVerifier interface {
+	VerifyToken(ctx context.Context, rawToken string) (*models.APIToken, error)
+	UpdateLastUsed(ctx context.Context, tokenID int64, ip string) error
+}
+
+// methodScopes is the token scope each method needs.
+var methodScopes = map[string]string{
+	methodCreate:     "tickets:write",
+	methodUpdate:     "tickets:write",
+	methodSearch:     "tickets:read",
+	methodBulkImport: "tickets:write",
+}
+
+type callerKey struct{}
+
+// caller is the authenticated agent behind a call.
+type caller struct {
+	userID int
+	token  *models.APIToken
+}
+
+func callerFrom(ctx context.Context) *caller {
+	c, _ := ctx.Value(callerKey{}).(*caller)
+	return c
+}
+
+// authenticate verifies the call's API token and returns a context
+// carrying the caller. Only agent tokens are accepted: the permission
+// checks are queue group permissions, which customers do not have.
+func (s *Server) authenticate(ctx context.Context, method string) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	var token string
+	if values := md.Get("authorization"); len(values) > 0 {
+		token = strings.TrimSpace(values[0])
+		if len(token) > 7 && strings.EqualFold(token[:7], "bearer ") {
+			token = strings.TrimSpace(token[7:])
+		}
+	}
+	if token == "" {
+		return nil, status.Error(codes.Unauthenticated, "missing API token")
+	}
+	if !strings.HasPrefix(token, models.TokenPrefix) {
+		return nil, status.Error(codes.Unauthenticated, "expected API token (gf_*)")
+	}
+
+	apiToken, err := s.verifier.VerifyToken(ctx, token)
+	if err != nil {
+		switch msg := err.Error(); {
+		case strings.Contains(msg, "expired"):
+			return nil, status.Error(codes.Unauthenticated, "API token expired")
+		case strings.Contains(msg, "revoked"):
+			return nil, status.Error(codes.Unauthenticated, "API token revoked")
+		}
+		return nil, status.Error(codes.Unauthenticated, "invalid API token")
+	}
+
+	clientIP := peerIP(ctx)
+	if !apiToken.AllowsIP(clientIP) {
+		return nil, status.Error(codes.PermissionDenied, "API token not allowed from this address")
+	}
+	if apiToken.UserType != models.APITokenUserAgent {
+		return nil, status.Error(codes.PermissionDenied, "customer tokens cannot use the ticket ingestion API")
+	}
+	if scope := methodScopes[method]; scope != "" && !apiToken.HasScope(scope) {
+		return nil, status.Errorf(codes.PermissionDenied, "token missing required scope: %s", scope)
+	}
+
+	verifier := s.verifier
+	go func() {
+		_ = verifier.UpdateLastUsed(context.Background(), apiToken.ID, clientIP)
+	}()
+
+	return context.WithValue(ctx, callerKey{}, &caller{userID: apiToken.UserID, token: apiToken}), nil
+}
+
+func peerIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
+}
+
+func (s *Server) unaryAuth(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, err := s.authenticate(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (s *Server) streamAuth(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := s.authenticate(ss.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	return handler(srv, &authedStream{ServerStream: ss, ctx: ctx})
+}
+
+// authedStream hands the authenticated context to stream handlers.
+type authedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authedStream) Context() context.Context { return s.ctx }
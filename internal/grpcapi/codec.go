@@ -0,0 +1,173 @@
+package grpcapi
+
+import (
+	"errors"
+	"fmt"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// The messages in this package are written by hand against
+// ticket_service.proto and encode themselves in the protobuf wire format,
+// so clients generated from the .proto file talk to the server unchanged.
+
+// message is implemented by every request and response type.
+type message interface {
+	appendTo(b []byte) []byte
+	unmarshal(b []byte) error
+}
+
+// codec is the gRPC codec for message types. It is only installed on this
+// package's server and client, leaving grpc's global proto codec alone.
+type codec struct{}
+
+func (codec) Marshal(v interface{}) ([]byte, error) {
+	m, ok := v.(message)
+	if !ok {
+		return nil, fmt.Errorf("grpcapi: cannot marshal %T", v)
+	}
+	return m.appendTo(nil), nil
+}
+
+func (codec) Unmarshal(data []byte, v interface{}) error {
+	m, ok := v.(message)
+	if !ok {
+		return fmt.Errorf("grpcapi: cannot unmarshal into %T", v)
+	}
+	return m.unmarshal(data)
+}
+
+func (codec) Name() string { return "proto" }
+
+var errWireType = errors.New("grpcapi: field has the wrong wire type")
+
+// field is one encoded field value.
+type field struct {
+	typ protowire.Type
+	raw []byte
+}
+
+// decodeFields calls fn for every field of an encoded message. Fields fn
+// does not know are skipped.
+func decodeFields(b []byte, fn func(num protowire.Number, f field) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+		n = protowire.ConsumeFieldValue(num, typ, b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		if err := fn(num, field{typ: typ, raw: b[:n]}); err != nil {
+			return err
+		}
+		b = b[n:]
+	}
+	return nil
+}
+
+func (f field) int64() (int64, error) {
+	if f.typ != protowire.VarintType {
+		return 0, errWireType
+	}
+	v, _ := protowire.ConsumeVarint(f.raw)
+	return int64(v), nil
+}
+
+func (f field) int32() (int32, error) {
+	v, err := f.int64()
+	return int32(v), err
+}
+
+func (f field) bytes() ([]byte, error) {
+	if f.typ != protowire.BytesType {
+		return nil, errWireType
+	}
+	v, _ := protowire.ConsumeBytes(f.raw)
+	return v, nil
+}
+
+func (f field) string() (string, error) {
+	v, err := f.bytes()
+	return string(v), err
+}
+
+// appendInt32s reads a repeated int32, which encoders may send packed or
+// one value per field.
+func (f field) appendInt32s(dst []int32) ([]int32, error) {
+	if f.typ == protowire.VarintType {
+		v, err := f.int32()
+		return append(dst, v), err
+	}
+	packed, err := f.bytes()
+	if err != nil {
+		return dst, err
+	}
+	for len(packed) > 0 {
+		v, n := protowire.ConsumeVarint(packed)
+		if n < 0 {
+			return dst, protowire.ParseError(n)
+		}
+		dst = append(dst, int32(v))
+		packed = packed[n:]
+	}
+	return dst, nil
+}
+
+// The append helpers leave out zero values, as proto3 does for fields
+// without presence; the optional variants write any set value.
+
+func appendInt64(b []byte, num protowire.Number, v int64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, uint64(v))
+}
+
+func appendInt32(b []byte, num protowire.Number, v int32) []byte {
+	return appendInt64(b, num, int64(v))
+}
+
+func appendString(b []byte, num protowire.Number, v string) []byte {
+	if v == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, v)
+}
+
+func appendOptionalInt32(b []byte, num protowire.Number, v *int32) []byte {
+	if v == nil {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, uint64(int64(*v)))
+}
+
+func appendOptionalString(b []byte, num protowire.Number, v *string) []byte {
+	if v == nil {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, *v)
+}
+
+func appendPackedInt32s(b []byte, num protowire.Number, v []int32) []byte {
+	if len(v) == 0 {
+		return b
+	}
+	var packed []byte
+	for _, n := range v {
+		packed = protowire.AppendVarint(packed, uint64(int64(n)))
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, packed)
+}
+
+func appendMessage(b []byte, num protowire.Number, m message) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, m.appendTo(nil))
+}
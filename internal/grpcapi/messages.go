@@ -0,0 +1,326 @@
+package grpcapi
+
+import (
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// Ticket is a ticket as returned by Create, Update and Search.
+type Ticket struct {
+	ID             int64
+	Number         string
+	Title          string
+	QueueID        int32
+	StateID        int32
+	State          string
+	StateType      string
+	PriorityID     int32
+	TypeID         int32
+	OwnerID        int32
+	ResponsibleID  int32
+	CustomerID     string
+	CustomerUserID string
+	CreateTime     int64 // Unix seconds
+	ChangeTime     int64 // Unix seconds
+}
+
+func (m *Ticket) appendTo(b []byte) []byte {
+	b = appendInt64(b, 1, m.ID)
+	b = appendString(b, 2, m.Number)
+	b = appendString(b, 3, m.Title)
+	b = appendInt32(b, 4, m.QueueID)
+	b = appendInt32(b, 5, m.StateID)
+	b = appendString(b, 6, m.State)
+	b = appendString(b, 7, m.StateType)
+	b = appendInt32(b, 8, m.PriorityID)
+	b = appendInt32(b, 9, m.TypeID)
+	b = appendInt32(b, 10, m.OwnerID)
+	b = appendInt32(b, 11, m.ResponsibleID)
+	b = appendString(b, 12, m.CustomerID)
+	b = appendString(b, 13, m.CustomerUserID)
+	b = appendInt64(b, 14, m.CreateTime)
+	return appendInt64(b, 15, m.ChangeTime)
+}
+
+func (m *Ticket) unmarshal(b []byte) error {
+	*m = Ticket{}
+	return decodeFields(b, func(num protowire.Number, f field) (err error) {
+		switch num {
+		case 1:
+			m.ID, err = f.int64()
+		case 2:
+			m.Number, err = f.string()
+		case 3:
+			m.Title, err = f.string()
+		case 4:
+			m.QueueID, err = f.int32()
+		case 5:
+			m.StateID, err = f.int32()
+		case 6:
+			m.State, err = f.string()
+		case 7:
+			m.StateType, err = f.string()
+		case 8:
+			m.PriorityID, err = f.int32()
+		case 9:
+			m.TypeID, err = f.int32()
+		case 10:
+			m.OwnerID, err = f.int32()
+		case 11:
+			m.ResponsibleID, err = f.int32()
+		case 12:
+			m.CustomerID, err = f.string()
+		case 13:
+			m.CustomerUserID, err = f.string()
+		case 14:
+			m.CreateTime, err = f.int64()
+		case 15:
+			m.ChangeTime, err = f.int64()
+		}
+		return err
+	})
+}
+
+// CreateTicketRequest is the input of Create and each message of a
+// BulkImport stream.
+type CreateTicketRequest struct {
+	Title          string
+	QueueID        int32
+	PriorityID     int32 // Defaults to 3 normal
+	StateID        int32 // Defaults to new
+	TypeID         int32
+	CustomerID     string
+	CustomerUserID string
+	Body           string // First article; none when empty
+	ContentType    string // Of Body; defaults to text/plain
+	Reference      string // Echoed in BulkImportResult
+}
+
+func (m *CreateTicketRequest) appendTo(b []byte) []byte {
+	b = appendString(b, 1, m.Title)
+	b = appendInt32(b, 2, m.QueueID)
+	b = appendInt32(b, 3, m.PriorityID)
+	b = appendInt32(b, 4, m.StateID)
+	b = appendInt32(b, 5, m.TypeID)
+	b = appendString(b, 6, m.CustomerID)
+	b = appendString(b, 7, m.CustomerUserID)
+	b = appendString(b, 8, m.Body)
+	b = appendString(b, 9, m.ContentType)
+	return appendString(b, 10, m.Reference)
+}
+
+func (m *CreateTicketRequest) unmarshal(b []byte) error {
+	*m = CreateTicketRequest{}
+	return decodeFields(b, func(num protowire.Number, f field) (err error) {
+		switch num {
+		case 1:
+			m.Title, err = f.string()
+		case 2:
+			m.QueueID, err = f.int32()
+		case 3:
+			m.PriorityID, err = f.int32()
+		case 4:
+			m.StateID, err = f.int32()
+		case 5:
+			m.TypeID, err = f.int32()
+		case 6:
+			m.CustomerID, err = f.string()
+		case 7:
+			m.CustomerUserID, err = f.string()
+		case 8:
+			m.Body, err = f.string()
+		case 9:
+			m.ContentType, err = f.string()
+		case 10:
+			m.Reference, err = f.string()
+		}
+		return err
+	})
+}
+
+// UpdateTicketRequest changes the fields that are not nil.
+type UpdateTicketRequest struct {
+	ID             int64
+	Title          *string
+	QueueID        *int32
+	StateID        *int32
+	PriorityID     *int32
+	TypeID         *int32
+	CustomerID     *string
+	CustomerUserID *string
+	OwnerID        *int32
+	ResponsibleID  *int32
+}
+
+func (m *UpdateTicketRequest) appendTo(b []byte) []byte {
+	b = appendInt64(b, 1, m.ID)
+	b = appendOptionalString(b, 2, m.Title)
+	b = appendOptionalInt32(b, 3, m.QueueID)
+	b = appendOptionalInt32(b, 4, m.StateID)
+	b = appendOptionalInt32(b, 5, m.PriorityID)
+	b = appendOptionalInt32(b, 6, m.TypeID)
+	b = appendOptionalString(b, 7, m.CustomerID)
+	b = appendOptionalString(b, 8, m.CustomerUserID)
+	b = appendOptionalInt32(b, 9, m.OwnerID)
+	return appendOptionalInt32(b, 10, m.ResponsibleID)
+}
+
+func (m *UpdateTicketRequest) unmarshal(b []byte) error {
+	*m = UpdateTicketRequest{}
+	optionalString := func(f field) (*string, error) {
+		v, err := f.string()
+		return &v, err
+	}
+	optionalInt32 := func(f field) (*int32, error) {
+		v, err := f.int32()
+		return &v, err
+	}
+	return decodeFields(b, func(num protowire.Number, f field) (err error) {
+		switch num {
+		case 1:
+			m.ID, err = f.int64()
+		case 2:
+			m.Title, err = optionalString(f)
+		case 3:
+			m.QueueID, err = optionalInt32(f)
+		case 4:
+			m.StateID, err = optionalInt32(f)
+		case 5:
+			m.PriorityID, err = optionalInt32(f)
+		case 6:
+			m.TypeID, err = optionalInt32(f)
+		case 7:
+			m.CustomerID, err = optionalString(f)
+		case 8:
+			m.CustomerUserID, err = optionalString(f)
+		case 9:
+			m.OwnerID, err = optionalInt32(f)
+		case 10:
+			m.ResponsibleID, err = optionalInt32(f)
+		}
+		return err
+	})
+}
+
+// SearchTicketsRequest filters Search; empty fields do not filter.
+type SearchTicketsRequest struct {
+	Query          string // Matches ticket number or title
+	QueueIDs       []int32
+	StateType      string
+	CustomerID     string
+	CustomerUserID string
+	ChangedAfter   int64 // Unix seconds
+	Limit          int32 // Defaults to DefaultSearchLimit
+	PageToken      string
+}
+
+func (m *SearchTicketsRequest) appendTo(b []byte) []byte {
+	b = appendString(b, 1, m.Query)
+	b = appendPackedInt32s(b, 2, m.QueueIDs)
+	b = appendString(b, 3, m.StateType)
+	b = appendString(b, 4, m.CustomerID)
+	b = appendString(b, 5, m.CustomerUserID)
+	b = appendInt64(b, 6, m.ChangedAfter)
+	b = appendInt32(b, 7, m.Limit)
+	return appendString(b, 8, m.PageToken)
+}
+
+func (m *SearchTicketsRequest) unmarshal(b []byte) error {
+	*m = SearchTicketsRequest{}
+	return decodeFields(b, func(num protowire.Number, f field) (err error) {
+		switch num {
+		case 1:
+			m.Query, err = f.string()
+		case 2:
+			m.QueueIDs, err = f.appendInt32s(m.QueueIDs)
+		case 3:
+			m.StateType, err = f.string()
+		case 4:
+			m.CustomerID, err = f.string()
+		case 5:
+			m.CustomerUserID, err = f.string()
+		case 6:
+			m.ChangedAfter, err = f.int64()
+		case 7:
+			m.Limit, err = f.int32()
+		case 8:
+			m.PageToken, err = f.string()
+		}
+		return err
+	})
+}
+
+// SearchTicketsResponse is one page of Search results.
+type SearchTicketsResponse struct {
+	Tickets       []*Ticket
+	NextPageToken string // Empty on the last page
+}
+
+func (m *SearchTicketsResponse) appendTo(b []byte) []byte {
+	for _, t := range m.Tickets {
+		b = appendMessage(b, 1, t)
+	}
+	return appendString(b, 2, m.NextPageToken)
+}
+
+func (m *SearchTicketsResponse) unmarshal(b []byte) error {
+	*m = SearchTicketsResponse{}
+	return decodeFields(b, func(num protowire.Number, f field) error {
+		switch num {
+		case 1:
+			raw, err := f.bytes()
+			if err != nil {
+				return err
+			}
+			t := &Ticket{}
+			if err := t.unmarshal(raw); err != nil {
+				return err
+			}
+			m.Tickets = append(m.Tickets, t)
+		case 2:
+			var err error
+			m.NextPageToken, err = f.string()
+			return err
+		}
+		return nil
+	})
+}
+
+// BulkImportResult answers one message of a BulkImport stream.
+type BulkImportResult struct {
+	Index     int64 // Position of the request in the stream, from 0
+	Reference string
+	TicketID  int64
+	Number    string
+	Code      int32 // gRPC status code; 0 when the ticket was created
+	Error     string
+}
+
+func (m *BulkImportResult) appendTo(b []byte) []byte {
+	b = appendInt64(b, 1, m.Index)
+	b = appendString(b, 2, m.Reference)
+	b = appendInt64(b, 3, m.TicketID)
+	b = appendString(b, 4, m.Number)
+	b = appendInt32(b, 5, m.Code)
+	return appendString(b, 6, m.Error)
+}
+
+func (m *BulkImportResult) unmarshal(b []byte) error {
+	*m = BulkImportResult{}
+	return decodeFields(b, func(num protowire.Number, f field) (err error) {
+		switch num {
+		case 1:
+			m.Index, err = f.int64()
+		case 2:
+			m.Reference, err = f.string()
+		case 3:
+			m.TicketID, err = f.int64()
+		case 4:
+			m.Number, err = f.string()
+		case 5:
+			m.Code, err = f.int32()
+		case 6:
+			m.Error, err = f.string()
+		}
+		return err
+	})
+}
@@ -0,0 +1,85 @@
+// Package grpcapi serves the gRPC ticket ingestion API on a port of its own.
+//
+// TicketService (ticket_service.proto) offers Create, Update, Search and a
+// streaming BulkImport for integrations that push tickets in volume. Calls
+// authenticate with the same gf_* API tokens as the HTTP API, and every
+// ticket goes through the same queue permission checks.
+package grpcapi
+
+import (
+	"database/sql"
+	"fmt"
+	"net"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+
+	"github.com/goatkit/goatflow/internal/repository"
+	"github.com/goatkit/goatflow/internal/service"
+	"github.com/goatkit/goatflow/internal/services"
+)
+
+// DefaultMaxMessageBytes caps request and response messages unless
+// Options.MaxMessageBytes says otherwise.
+const DefaultMaxMessageBytes = 4 << 20
+
+// Options tune a Server.
+type Options struct {
+	MaxMessageBytes int    // 0 means DefaultMaxMessageBytes
+	TLSCertFile     string // Plaintext when empty
+	TLSKeyFile      string
+}
+
+// Server is the gRPC ticket ingestion server.
+type Server struct {
+	db       *sql.DB
+	verifier TokenVerifier
+	tickets  service.TicketService
+	perms    *services.PermissionService
+	grpc     *grpc.Server
+}
+
+// NewServer returns a server for db. It fails only when the TLS key pair
+// cannot be loaded.
+func NewServer(db *sql.DB, verifier TokenVerifier, opts Options) (*Server, error) {
+	s := &Server{
+		db:       db,
+		verifier: verifier,
+		tickets: service.NewTicketService(repository.NewTicketRepository(db),
+			service.WithArticleRepository(repository.NewArticleRepository(db))),
+		perms: services.NewPermissionService(db),
+	}
+
+	maxBytes := opts.MaxMessageBytes
+	if maxBytes <= 0 {
+		maxBytes = DefaultMaxMessageBytes
+	}
+	serverOpts := []grpc.ServerOption{
+		grpc.ForceServerCodec(codec{}),
+		grpc.MaxRecvMsgSize(maxBytes),
+		grpc.MaxSendMsgSize(maxBytes),
+		grpc.UnaryInterceptor(s.unaryAuth),
+		grpc.StreamInterceptor(s.streamAuth),
+	}
+	if opts.TLSCertFile != "" || opts.TLSKeyFile != "" {
+		creds, err := credentials.NewServerTLSFromFile(opts.TLSCertFile, opts.TLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("load gRPC TLS key pair: %w", err)
+		}
+		serverOpts = append(serverOpts, grpc.Creds(creds))
+	}
+
+	s.grpc = grpc.NewServer(serverOpts...)
+	s.grpc.RegisterService(&serviceDesc, s)
+	return s, nil
+}
+
+// Serve accepts connections on lis until Stop is called.
+func (s *Server) Serve(lis net.Listener) error {
+	return s.grpc.Serve(lis)
+}
+
+// Stop stops accepting calls and waits for the running ones to finish.
+func (s *Server) Stop() {
+	s.grpc.GracefulStop()
+}
@@ -0,0 +1,261 @@
+package grpcapi
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/service"
+	"github.com/goatkit/goatflow/internal/testutil"
+)
+
+type fakeVerifier map[string]*models.APIToken
+
+func (v fakeVerifier) VerifyToken(_ context.Context, raw string) (*models.APIToken, error) {
+	if t, ok := v[raw]; ok {
+		return t, nil
+	}
+	return nil, errors.New("token not found")
+}
+
+func (fakeVerifier) UpdateLastUsed(context.Context, int64, string) error { return nil }
+
+// fakeTickets inserts bare ticket rows, standing in for the repository
+// based ticket service.
+type fakeTickets struct{ db *sql.DB }
+
+func (f fakeTickets) Create(_ context.Context, in service.CreateTicketInput) (*models.Ticket, error) {
+	var id int64
+	if err := f.db.QueryRow(`SELECT COALESCE(MAX(id), 0) + 1 FROM ticket`).Scan(&id); err != nil {
+		return nil, err
+	}
+	number := "20260201" + string(rune('0'+id))
+	_, err := f.db.Exec(`INSERT INTO ticket (id, tn, title, queue_id, ticket_lock_id, ticket_state_id, ticket_priority_id,
+		user_id, responsible_user_id, customer_id, customer_user_id, timeout, until_time, escalation_time,
+		escalation_update_time, escalation_response_time, escalation_solution_time, archive_flag,
+		create_time, create_by, change_time, change_by)
+		VALUES (?, ?, ?, ?, 1, 2, 3, ?, ?, ?, ?, 0, 0, 0, 0, 0, 0, 0, ?, ?, ?, ?)`,
+		id, number, in.Title, in.QueueID, in.UserID, in.UserID, in.CustomerID, in.CustomerUserID,
+		"2026-02-01 10:00:00", in.UserID, "2026-02-01 10:00:00", in.UserID)
+	if err != nil {
+		return nil, err
+	}
+	return &models.Ticket{ID: int(id), TicketNumber: number, QueueID: in.QueueID}, nil
+}
+
+// newTestClient serves a Server over an in-memory connection. Agent 1 can
+// read Support (ro), create in Finance, and has rw on Sales.
+func newTestClient(t *testing.T) (*TicketServiceClient, *sql.DB) {
+	t.Helper()
+	db := testutil.MigratedDB(t)
+	for _, stmt := range []string{
+		`INSERT INTO users (id, login, pw, first_name, last_name, valid_id, create_time, create_by, change_time, change_by)
+			VALUES (1, 'agent', 'x', 'Ada', 'Agent', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+		`INSERT INTO groups (id, name, valid_id, create_time, create_by, change_time, change_by)
+			VALUES (10, 'support', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1),
+			       (20, 'finance', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1),
+			       (30, 'sales', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+		`UPDATE queue SET name = 'Support', group_id = 10 WHERE id = 1`,
+		`UPDATE queue SET name = 'Finance', group_id = 20 WHERE id = 2`,
+		`UPDATE queue SET name = 'Sales', group_id = 30 WHERE id = 3`,
+		`INSERT INTO group_user (user_id, group_id, permission_key, create_time, create_by, change_time, change_by)
+			VALUES (1, 10, 'ro', CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1),
+			       (1, 20, 'create', CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1),
+			       (1, 30, 'rw', CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+		// 1003 is closed, the others open.
+		`INSERT INTO ticket (id, tn, title, queue_id, ticket_lock_id, ticket_state_id, ticket_priority_id, user_id,
+			responsible_user_id, customer_id, timeout, until_time, escalation_time, escalation_update_time,
+			escalation_response_time, escalation_solution_time, archive_flag, create_time, create_by, change_time, change_by) VALUES
+			(1, '1001', 'Printer', 1, 1, 2, 3, 1, 1, 'ACME', 0, 0, 0, 0, 0, 0, 0, '2026-01-01 10:00:00', 1, '2026-01-01 10:00:00', 1),
+			(2, '1002', 'Invoice', 2, 1, 2, 3, 1, 1, 'ACME', 0, 0, 0, 0, 0, 0, 0, '2026-01-02 10:00:00', 1, '2026-01-02 10:00:00', 1),
+			(3, '1003', 'Printer toner', 3, 1, 4, 3, 1, 1, 'ACME', 0, 0, 0, 0, 0, 0, 0, '2026-01-03 10:00:00', 1, '2026-01-03 10:00:00', 1),
+			(4, '1004', 'Demo', 3, 1, 2, 3, 1, 1, 'Globex', 0, 0, 0, 0, 0, 0, 0, '2026-01-04 10:00:00', 1, '2026-01-04 10:00:00', 1)`,
+	} {
+		_, err := db.Exec(stmt)
+		require.NoError(t, err, stmt)
+	}
+
+	s, err := NewServer(db, fakeVerifier{
+		"gf_agent_token":    {ID: 1, UserID: 1, UserType: models.APITokenUserAgent},
+		"gf_reader_token":   {ID: 2, UserID: 1, UserType: models.APITokenUserAgent, Scopes: []string{"tickets:read"}},
+		"gf_customer_token": {ID: 3, UserID: 9, UserType: models.APITokenUserCustomer},
+	}, Options{})
+	require.NoError(t, err)
+	s.tickets = fakeTickets{db}
+
+	lis := bufconn.Listen(1 << 20)
+	go func() { _ = s.Serve(lis) }()
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return NewTicketServiceClient(conn), db
+}
+
+func withToken(t *testing.T, token string) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	t.Cleanup(cancel)
+	return metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+token)
+}
+
+func TestMessages_RoundTrip(t *testing.T) {
+	title, zero := "", int32(0)
+	update := &UpdateTicketRequest{ID: 7, Title: &title, OwnerID: &zero}
+	var gotUpdate UpdateTicketRequest
+	require.NoError(t, gotUpdate.unmarshal(update.appendTo(nil)))
+	assert.Equal(t, *update, gotUpdate, "set optional fields survive even when zero")
+
+	search := &SearchTicketsRequest{Query: "printer", QueueIDs: []int32{1, 3}, ChangedAfter: 1767225600, Limit: -1}
+	var gotSearch SearchTicketsRequest
+	require.NoError(t, gotSearch.unmarshal(search.appendTo(nil)))
+	assert.Equal(t, *search, gotSearch)
+
+	// Unpacked repeated values and unknown fields, as other encoders may send.
+	raw := appendInt32(appendInt32(nil, 2, 4), 2, 5)
+	raw = appendString(raw, 99, "ignored")
+	require.NoError(t, gotSearch.unmarshal(raw))
+	assert.Equal(t, SearchTicketsRequest{QueueIDs: []int32{4, 5}}, gotSearch)
+
+	assert.Error(t, gotSearch.unmarshal(appendInt32(nil, 1, 1)), "query sent as a varint")
+}
+
+func TestServer_Authentication(t *testing.T) {
+	client, _ := newTestClient(t)
+	for _, tc := range []struct {
+		ctx  context.Context
+		code codes.Code
+		msg  string
+	}{
+		{context.Background(), codes.Unauthenticated, "missing API token"},
+		{withToken(t, "eyJhbGciOi.jwt"), codes.Unauthenticated, "expected API token (gf_*)"},
+		{withToken(t, "gf_unknown"), codes.Unauthenticated, "invalid API token"},
+		{withToken(t, "gf_customer_token"), codes.PermissionDenied, "customer tokens cannot use the ticket ingestion API"},
+		{withToken(t, "gf_reader_token"), codes.PermissionDenied, "token missing required scope: tickets:write"},
+	} {
+		_, err := client.Create(tc.ctx, &CreateTicketRequest{Title: "x", QueueID: 3})
+		assert.Equal(t, tc.code, status.Code(err), tc.msg)
+		assert.Equal(t, tc.msg, status.Convert(err).Message())
+	}
+
+	_, err := client.Search(withToken(t, "gf_reader_token"), &SearchTicketsRequest{})
+	assert.NoError(t, err)
+}
+
+func TestServer_CreateAndBulkImport(t *testing.T) {
+	client, _ := newTestClient(t)
+
+	created, err := client.Create(withToken(t, "gf_agent_token"), &CreateTicketRequest{Title: " Laptop ", QueueID: 2, CustomerID: "ACME"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), created.ID)
+	assert.Equal(t, "Laptop", created.Title)
+	assert.Equal(t, "open", created.StateType)
+
+	_, err = client.Create(withToken(t, "gf_agent_token"), &CreateTicketRequest{Title: "Nope", QueueID: 1})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	stream, err := client.BulkImport(withToken(t, "gf_agent_token"))
+	require.NoError(t, err)
+	for _, req := range []*CreateTicketRequest{
+		{Title: "One", QueueID: 3, Reference: "ext-1"},
+		{Title: "", QueueID: 3, Reference: "ext-2"},
+		{Title: "Three", QueueID: 1, Reference: "ext-3"},
+		{Title: "Four", QueueID: 2, Reference: "ext-4"},
+	} {
+		require.NoError(t, stream.Send(req))
+	}
+	require.NoError(t, stream.CloseSend())
+
+	var results []*BulkImportResult
+	for {
+		res, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		results = append(results, res)
+	}
+	require.Len(t, results, 4)
+	assert.Equal(t, BulkImportResult{Index: 0, Reference: "ext-1", TicketID: 6, Number: "202602016"}, *results[0])
+	assert.Equal(t, BulkImportResult{Index: 1, Reference: "ext-2", Code: int32(codes.InvalidArgument),
+		Error: "title is required"}, *results[1])
+	assert.Equal(t, int32(codes.PermissionDenied), results[2].Code)
+	assert.Equal(t, int64(7), results[3].TicketID)
+}
+
+func TestServer_Update(t *testing.T) {
+	client, db := newTestClient(t)
+	ctx := withToken(t, "gf_agent_token")
+
+	title, priority := "Printer toner (black)", int32(5)
+	updated, err := client.Update(ctx, &UpdateTicketRequest{ID: 3, Title: &title, PriorityID: &priority})
+	require.NoError(t, err)
+	assert.Equal(t, title, updated.Title)
+	assert.Equal(t, int32(5), updated.PriorityID)
+	var changeBy int
+	require.NoError(t, db.QueryRow(`SELECT change_by FROM ticket WHERE id = 3`).Scan(&changeBy))
+	assert.Equal(t, 1, changeBy)
+
+	bad := int32(9)
+	for _, tc := range []struct {
+		req  *UpdateTicketRequest
+		code codes.Code
+	}{
+		{&UpdateTicketRequest{ID: 99, Title: &title}, codes.NotFound},
+		{&UpdateTicketRequest{ID: 1, Title: &title}, codes.PermissionDenied},
+		{&UpdateTicketRequest{ID: 3, PriorityID: &bad}, codes.InvalidArgument},
+		{&UpdateTicketRequest{ID: 3}, codes.InvalidArgument},
+	} {
+		_, err := client.Update(ctx, tc.req)
+		assert.Equal(t, tc.code, status.Code(err), "ticket %d", tc.req.ID)
+	}
+}
+
+func TestServer_Search(t *testing.T) {
+	client, _ := newTestClient(t)
+	ctx := withToken(t, "gf_reader_token")
+
+	page, err := client.Search(ctx, &SearchTicketsRequest{Limit: 2})
+	require.NoError(t, err)
+	numbers := func(p *SearchTicketsResponse) []string {
+		var out []string
+		for _, t := range p.Tickets {
+			out = append(out, t.Number)
+		}
+		return out
+	}
+	assert.Equal(t, []string{"1004", "1003"}, numbers(page), "Finance is create-only, so 1002 is hidden")
+	require.NotEmpty(t, page.NextPageToken)
+
+	page, err = client.Search(ctx, &SearchTicketsRequest{Limit: 2, PageToken: page.NextPageToken})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1001"}, numbers(page))
+	assert.Empty(t, page.NextPageToken)
+
+	page, err = client.Search(ctx, &SearchTicketsRequest{Query: "PRINTER", StateType: "open"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1001"}, numbers(page))
+
+	page, err = client.Search(ctx, &SearchTicketsRequest{QueueIDs: []int32{2, 3}, CustomerID: "ACME"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1003"}, numbers(page))
+
+	_, err = client.Search(ctx, &SearchTicketsRequest{PageToken: "nope"})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
@@ -0,0 +1,139 @@
+package grpcapi
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+)
+
+const serviceName = "goatflow.ingest.v1.TicketService"
+
+const (
+	methodCreate     = "/" + serviceName + "/Create"
+	methodUpdate     = "/" + serviceName + "/Update"
+	methodSearch     = "/" + serviceName + "/Search"
+	methodBulkImport = "/" + serviceName + "/BulkImport"
+)
+
+// serviceDesc is what protoc-gen-go-grpc would generate for TicketService.
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: serviceName,
+	HandlerType: (*interface{})(nil),
+	Methods: []grpc.MethodDesc{
+		{MethodName: "Create", Handler: unaryHandler(methodCreate, func() message { return &CreateTicketRequest{} },
+			func(s *Server, ctx context.Context, req message) (message, error) {
+				return s.Create(ctx, req.(*CreateTicketRequest))
+			})},
+		{MethodName: "Update", Handler: unaryHandler(methodUpdate, func() message { return &UpdateTicketRequest{} },
+			func(s *Server, ctx context.Context, req message) (message, error) {
+				return s.Update(ctx, req.(*UpdateTicketRequest))
+			})},
+		{MethodName: "Search", Handler: unaryHandler(methodSearch, func() message { return &SearchTicketsRequest{} },
+			func(s *Server, ctx context.Context, req message) (message, error) {
+				return s.Search(ctx, req.(*SearchTicketsRequest))
+			})},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName: "BulkImport",
+			Handler: func(srv interface{}, stream grpc.ServerStream) error {
+				return srv.(*Server).BulkImport(stream)
+			},
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "ticket_service.proto",
+}
+
+func unaryHandler(method string, newReq func() message, call func(*Server, context.Context, message) (message, error)) func(interface{}, context.Context, func(interface{}) error, grpc.UnaryServerInterceptor) (interface{}, error) {
+	return func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+		req := newReq()
+		if err := dec(req); err != nil {
+			return nil, err
+		}
+		handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+			return call(srv.(*Server), ctx, req.(message))
+		}
+		if interceptor == nil {
+			return handler(ctx, req)
+		}
+		return interceptor(ctx, req, &grpc.UnaryServerInfo{Server: srv, FullMethod: method}, handler)
+	}
+}
+
+// TicketServiceClient calls TicketService from Go.
+type TicketServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+// NewTicketServiceClient returns a client using cc.
+func NewTicketServiceClient(cc grpc.ClientConnInterface) *TicketServiceClient {
+	return &TicketServiceClient{cc: cc}
+}
+
+func (c *TicketServiceClient) invoke(ctx context.Context, method string, req, resp message, opts []grpc.CallOption) error {
+	return c.cc.Invoke(ctx, method, req, resp, append([]grpc.CallOption{grpc.ForceCodec(codec{})}, opts...)...)
+}
+
+// Create calls TicketService.Create.
+func (c *TicketServiceClient) Create(ctx context.Context, req *CreateTicketRequest, opts ...grpc.CallOption) (*Ticket, error) {
+	resp := &Ticket{}
+	if err := c.invoke(ctx, methodCreate, req, resp, opts); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// Update calls TicketService.Update.
+func (c *TicketServiceClient) Update(ctx context.Context, req *UpdateTicketRequest, opts ...grpc.CallOption) (*Ticket, error) {
+	resp := &Ticket{}
+	if err := c.invoke(ctx, methodUpdate, req, resp, opts); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// Search calls TicketService.Search.
+func (c *TicketServiceClient) Search(ctx context.Context, req *SearchTicketsRequest, opts ...grpc.CallOption) (*SearchTicketsResponse, error) {
+	resp := &SearchTicketsResponse{}
+	if err := c.invoke(ctx, methodSearch, req, resp, opts); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// BulkImport opens a TicketService.BulkImport stream.
+func (c *TicketServiceClient) BulkImport(ctx context.Context, opts ...grpc.CallOption) (*BulkImportStream, error) {
+	stream, err := c.cc.NewStream(ctx, &serviceDesc.Streams[0], methodBulkImport,
+		append([]grpc.CallOption{grpc.ForceCodec(codec{})}, opts...)...)
+	if err != nil {
+		return nil, err
+	}
+	return &BulkImportStream{stream: stream}, nil
+}
+
+// BulkImportStream is the client side of a BulkImport call. Sending and
+// receiving may run in separate goroutines.
+type BulkImportStream struct {
+	stream grpc.ClientStream
+}
+
+// Send queues one ticket for creation.
+func (s *BulkImportStream) Send(req *CreateTicketRequest) error {
+	return s.stream.SendMsg(req)
+}
+
+// CloseSend tells the server no more tickets follow.
+func (s *BulkImportStream) CloseSend() error {
+	return s.stream.CloseSend()
+}
+
+// Recv returns the next result, or io.EOF after the last one.
+func (s *BulkImportStream) Recv() (*BulkImportResult, error) {
+	resp := &BulkImportResult{}
+	if err := s.stream.RecvMsg(resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
@@ -0,0 +1,440 @@
+package grpcapi
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/goatkit/goatflow/internal/config"
+	"github.com/goatkit/goatflow/internal/constants"
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/mailqueue"
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/service"
+)
+
+// Search page sizes.
+const (
+	DefaultSearchLimit = 50
+	MaxSearchLimit     = 500
+)
+
+var errInvalidPageToken = status.Error(codes.InvalidArgument, "invalid page_token")
+
+// Create creates one ticket and returns it.
+func (s *Server) Create(ctx context.Context, req *CreateTicketRequest) (*Ticket, error) {
+	created, err := s.createTicket(ctx, callerFrom(ctx), req, nil)
+	if err != nil {
+		return nil, err
+	}
+	return s.loadTicket(ctx, int64(created.ID))
+}
+
+// BulkImport creates the tickets sent on stream, answering each with a
+// BulkImportResult. Queue permissions are looked up once per stream. The
+// stream only fails as a whole when the database is unavailable; results
+// already sent stand, so clients resume after the last index they got.
+func (s *Server) BulkImport(stream grpc.ServerStream) error {
+	ctx := stream.Context()
+	who := callerFrom(ctx)
+	canCreate := map[int]bool{}
+	for index := int64(0); ; index++ {
+		req := &CreateTicketRequest{}
+		if err := stream.RecvMsg(req); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+
+		result := &BulkImportResult{Index: index, Reference: req.Reference}
+		created, err := s.createTicket(ctx, who, req, canCreate)
+		if err != nil {
+			st := status.Convert(err)
+			if st.Code() == codes.Unavailable {
+				return err
+			}
+			result.Code = int32(st.Code())
+			result.Error = st.Message()
+		} else {
+			result.TicketID = int64(created.ID)
+			result.Number = created.TicketNumber
+		}
+		if err := stream.SendMsg(result); err != nil {
+			return err
+		}
+	}
+}
+
+// createTicket checks the caller may create in the target queue and
+// creates the ticket like POST /api/v1/tickets does. canCreate caches
+// permission answers by queue when not nil.
+func (s *Server) createTicket(ctx context.Context, who *caller, req *CreateTicketRequest, canCreate map[int]bool) (*models.Ticket, error) {
+	title := strings.TrimSpace(req.Title)
+	queueID, priorityID, typeID := int(req.QueueID), int(req.PriorityID), int(req.TypeID)
+	if title == "" {
+		return nil, status.Error(codes.InvalidArgument, "title is required")
+	}
+	if typeID > 0 && queueID == 0 {
+		if err := service.NewTicketTypeService(s.db).ApplyDefaults(ctx, typeID, &queueID, &priorityID, nil); err != nil {
+			log.Printf("grpc: type %d defaults failed: %v", typeID, err)
+		}
+	}
+	if queueID <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "queue_id is required")
+	}
+
+	allowed, cached := canCreate[queueID]
+	if !cached {
+		var err error
+		if allowed, err = s.perms.CanCreate(who.userID, queueID); err != nil {
+			return nil, dbError("check permissions", err)
+		}
+		if canCreate != nil {
+			canCreate[queueID] = allowed
+		}
+	}
+	if !allowed {
+		return nil, status.Error(codes.PermissionDenied, "no permission to create tickets in this queue")
+	}
+
+	visible := true
+	created, err := s.tickets.Create(ctx, service.CreateTicketInput{
+		Title:                       title,
+		QueueID:                     queueID,
+		PriorityID:                  priorityID,
+		StateID:                     int(req.StateID),
+		UserID:                      who.userID,
+		Body:                        req.Body,
+		ArticleSubject:              title,
+		ArticleSenderTypeID:         constants.ArticleSenderAgent,
+		ArticleTypeID:               constants.ArticleTypeEmailExternal,
+		ArticleIsVisibleForCustomer: &visible,
+		ArticleMimeType:             req.ContentType,
+		TypeID:                      typeID,
+		CustomerID:                  req.CustomerID,
+		CustomerUserID:              req.CustomerUserID,
+	})
+	if err != nil {
+		if database.IsConnectionError(err) {
+			return nil, status.Error(codes.Unavailable, "database unavailable")
+		}
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return created, nil
+}
+
+// Update applies the fields set on req with the permission checks of
+// PUT /api/v1/tickets/:id and returns the updated ticket.
+func (s *Server) Update(ctx context.Context, req *UpdateTicketRequest) (*Ticket, error) {
+	userID := callerFrom(ctx).userID
+	if req.ID <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "id is required")
+	}
+
+	var queueID int
+	err := s.db.QueryRowContext(ctx, database.ConvertPlaceholders(
+		"SELECT queue_id FROM ticket WHERE id = ?"), req.ID).Scan(&queueID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, status.Error(codes.NotFound, "ticket not found")
+	}
+	if err != nil {
+		return nil, dbError("load ticket", err)
+	}
+
+	canWrite, err := s.perms.CanWriteTicket(userID, req.ID)
+	if err != nil {
+		return nil, dbError("check permissions", err)
+	}
+	if !canWrite {
+		return nil, status.Error(codes.PermissionDenied, "write access denied - requires 'rw' permission on queue")
+	}
+	if req.PriorityID != nil {
+		if ok, err := s.perms.CanChangePriority(userID, req.ID); err != nil {
+			return nil, dbError("check permissions", err)
+		} else if !ok {
+			return nil, status.Error(codes.PermissionDenied, "no permission to change priority")
+		}
+	}
+	if req.OwnerID != nil {
+		if ok, err := s.perms.CanBeOwner(userID, queueID); err != nil {
+			return nil, dbError("check permissions", err)
+		} else if !ok {
+			return nil, status.Error(codes.PermissionDenied, "no permission to change ticket owner")
+		}
+	}
+	if req.QueueID != nil {
+		if ok, err := s.perms.CanMoveInto(userID, int(*req.QueueID)); err != nil {
+			return nil, dbError("check permissions", err)
+		} else if !ok {
+			return nil, status.Error(codes.PermissionDenied, "no permission to move tickets into this queue")
+		}
+	}
+
+	if req.Title != nil {
+		if *req.Title == "" {
+			return nil, status.Error(codes.InvalidArgument, "title cannot be empty")
+		}
+		if len(*req.Title) > 255 {
+			return nil, status.Error(codes.InvalidArgument, "title too long (max 255 characters)")
+		}
+	}
+	for _, ref := range []struct {
+		table, name string
+		id          *int32
+	}{
+		{"queue", "queue_id", req.QueueID},
+		{"ticket_state", "state_id", req.StateID},
+		{"ticket_priority", "priority_id", req.PriorityID},
+		{"ticket_type", "type_id", req.TypeID},
+	} {
+		if ref.id == nil {
+			continue
+		}
+		var exists bool
+		err := s.db.QueryRowContext(ctx, database.ConvertPlaceholders(
+			"SELECT EXISTS(SELECT 1 FROM "+ref.table+" WHERE id = ? AND valid_id = 1)"), *ref.id).Scan(&exists)
+		if err != nil {
+			return nil, dbError("validate "+ref.name, err)
+		}
+		if !exists {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid %s", ref.name)
+		}
+	}
+
+	// The ticket type's workflow and approval rules apply as in the web UI.
+	if req.StateID != nil {
+		err := service.NewTicketTypeService(s.db).CheckTransition(ctx, int(req.ID), int(*req.StateID))
+		switch {
+		case errors.Is(err, service.ErrTicketTypeTransitionNotAllowed):
+			return nil, status.Error(codes.FailedPrecondition, err.Error())
+		case err != nil:
+			return nil, dbError("check ticket type workflow", err)
+		}
+		if err := s.checkApproval(ctx, models.ApprovalActionClose, int(req.ID), int(*req.StateID), userID); err != nil {
+			return nil, err
+		}
+	}
+	if req.QueueID != nil {
+		if err := s.checkApproval(ctx, models.ApprovalActionMove, int(req.ID), int(*req.QueueID), userID); err != nil {
+			return nil, err
+		}
+	}
+
+	var sets []string
+	var args []interface{}
+	set := func(column string, value interface{}) {
+		sets = append(sets, column+" = ?")
+		args = append(args, value)
+	}
+	if req.Title != nil {
+		set("title", *req.Title)
+	}
+	if req.QueueID != nil {
+		set("queue_id", *req.QueueID)
+	}
+	if req.StateID != nil {
+		set("ticket_state_id", *req.StateID)
+	}
+	if req.PriorityID != nil {
+		set("ticket_priority_id", *req.PriorityID)
+	}
+	if req.TypeID != nil {
+		set(database.TicketTypeColumn(), *req.TypeID)
+	}
+	if req.CustomerID != nil {
+		set("customer_id", *req.CustomerID)
+	}
+	if req.CustomerUserID != nil {
+		set("customer_user_id", *req.CustomerUserID)
+	}
+	if req.OwnerID != nil {
+		set("user_id", *req.OwnerID)
+	}
+	if req.ResponsibleID != nil {
+		set("responsible_user_id", *req.ResponsibleID)
+	}
+	if len(sets) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "no fields to update")
+	}
+	sets = append(sets, "change_time = CURRENT_TIMESTAMP")
+	set("change_by", userID)
+
+	if _, err := s.db.ExecContext(ctx, database.ConvertPlaceholders(
+		"UPDATE ticket SET "+strings.Join(sets, ", ")+" WHERE id = ?"), append(args, req.ID)...); err != nil {
+		return nil, dbError("update ticket", err)
+	}
+	return s.loadTicket(ctx, req.ID)
+}
+
+// checkApproval fails the update when action needs an approval first; a
+// request for it is raised as the web UI does.
+func (s *Server) checkApproval(ctx context.Context, action models.ApprovalAction, ticketID, targetID, userID int) error {
+	svc := service.NewApprovalService(s.db)
+	if cfg := config.Get(); cfg != nil && cfg.Email.Enabled && cfg.Email.From != "" {
+		svc.WithEmail(mailqueue.NewMailQueueRepository(s.db), cfg.Email.From)
+	}
+	var approval *models.ApprovalRequest
+	var err error
+	if action == models.ApprovalActionMove {
+		approval, err = svc.RequestMove(ctx, ticketID, targetID, userID, "")
+	} else {
+		approval, err = svc.RequestClose(ctx, ticketID, targetID, userID, "")
+	}
+	switch {
+	case errors.Is(err, service.ErrApprovalPending):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, service.ErrApprovalTicketNotFound):
+		return status.Error(codes.NotFound, "ticket not found")
+	case err != nil:
+		return dbError("check approval rules", err)
+	case approval != nil:
+		return status.Errorf(codes.FailedPrecondition, "approval required: request %d raised", approval.ID)
+	}
+	return nil
+}
+
+const ticketSelect = `
+	SELECT t.id, t.tn, COALESCE(t.title, ''), t.queue_id, t.ticket_state_id, ts.name, tst.name,
+	       t.ticket_priority_id, COALESCE(%s, 0), COALESCE(t.user_id, 0), COALESCE(t.responsible_user_id, 0),
+	       COALESCE(t.customer_id, ''), COALESCE(t.customer_user_id, ''), t.create_time, t.change_time
+	FROM ticket t
+	JOIN ticket_state ts ON ts.id = t.ticket_state_id
+	JOIN ticket_state_type tst ON tst.id = ts.type_id`
+
+func scanTicket(row interface{ Scan(...interface{}) error }) (*Ticket, error) {
+	var t Ticket
+	var created, changed time.Time
+	err := row.Scan(&t.ID, &t.Number, &t.Title, &t.QueueID, &t.StateID, &t.State, &t.StateType,
+		&t.PriorityID, &t.TypeID, &t.OwnerID, &t.ResponsibleID, &t.CustomerID, &t.CustomerUserID, &created, &changed)
+	t.CreateTime, t.ChangeTime = created.Unix(), changed.Unix()
+	return &t, err
+}
+
+func (s *Server) loadTicket(ctx context.Context, id int64) (*Ticket, error) {
+	query := fmt.Sprintf(ticketSelect, database.QualifiedTicketTypeColumn("t")) + " WHERE t.id = ?"
+	t, err := scanTicket(s.db.QueryRowContext(ctx, database.ConvertPlaceholders(query), id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, status.Error(codes.NotFound, "ticket not found")
+	}
+	if err != nil {
+		return nil, dbError("load ticket", err)
+	}
+	return t, nil
+}
+
+// Search returns one page of the tickets in queues the caller can read,
+// newest first.
+func (s *Server) Search(ctx context.Context, req *SearchTicketsRequest) (*SearchTicketsResponse, error) {
+	limit := int(req.Limit)
+	if limit <= 0 {
+		limit = DefaultSearchLimit
+	}
+	if limit > MaxSearchLimit {
+		limit = MaxSearchLimit
+	}
+
+	perms, err := s.perms.GetUserQueuePermissions(callerFrom(ctx).userID)
+	if err != nil {
+		return nil, dbError("check permissions", err)
+	}
+	wanted := map[int]bool{}
+	for _, id := range req.QueueIDs {
+		wanted[int(id)] = true
+	}
+	var queueIDs []interface{}
+	for id, perm := range perms {
+		if (perm == "ro" || perm == "rw") && (len(wanted) == 0 || wanted[id]) {
+			queueIDs = append(queueIDs, id)
+		}
+	}
+	resp := &SearchTicketsResponse{Tickets: []*Ticket{}}
+	if len(queueIDs) == 0 {
+		return resp, nil
+	}
+
+	where := []string{"t.queue_id IN (?" + strings.Repeat(", ?", len(queueIDs)-1) + ")"}
+	args := queueIDs
+	if q := strings.TrimSpace(req.Query); q != "" {
+		where = append(where, "(t.tn = ? OR LOWER(t.title) LIKE ?)")
+		args = append(args, q, "%"+strings.ToLower(q)+"%")
+	}
+	if req.StateType != "" {
+		where = append(where, "tst.name = ?")
+		args = append(args, req.StateType)
+	}
+	if req.CustomerID != "" {
+		where = append(where, "t.customer_id = ?")
+		args = append(args, req.CustomerID)
+	}
+	if req.CustomerUserID != "" {
+		where = append(where, "t.customer_user_id = ?")
+		args = append(args, req.CustomerUserID)
+	}
+	if req.ChangedAfter > 0 {
+		where = append(where, "t.change_time > ?")
+		args = append(args, time.Unix(req.ChangedAfter, 0).UTC())
+	}
+	if req.PageToken != "" {
+		before, err := decodePageToken(req.PageToken)
+		if err != nil {
+			return nil, err
+		}
+		where = append(where, "t.id < ?")
+		args = append(args, before)
+	}
+
+	query := fmt.Sprintf(ticketSelect, database.QualifiedTicketTypeColumn("t")) +
+		" WHERE " + strings.Join(where, " AND ") + " ORDER BY t.id DESC LIMIT " + strconv.Itoa(limit+1)
+	rows, err := s.db.QueryContext(ctx, database.ConvertPlaceholders(query), args...)
+	if err != nil {
+		return nil, dbError("search tickets", err)
+	}
+	defer rows.Close()
+	tickets, err := database.CollectRows(rows, func(rows *sql.Rows) (*Ticket, error) { return scanTicket(rows) })
+	if err != nil {
+		return nil, dbError("search tickets", err)
+	}
+	if len(tickets) > limit {
+		tickets = tickets[:limit]
+		resp.NextPageToken = encodePageToken(tickets[limit-1].ID)
+	}
+	resp.Tickets = tickets
+	return resp, nil
+}
+
+func encodePageToken(id int64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte("ticket:" + strconv.FormatInt(id, 10)))
+}
+
+func decodePageToken(token string) (int64, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || !strings.HasPrefix(string(raw), "ticket:") {
+		return 0, errInvalidPageToken
+	}
+	id, err := strconv.ParseInt(strings.TrimPrefix(string(raw), "ticket:"), 10, 64)
+	if err != nil || id <= 0 {
+		return 0, errInvalidPageToken
+	}
+	return id, nil
+}
+
+// dbError logs err and returns the status clients see for it.
+func dbError(action string, err error) error {
+	if database.IsConnectionError(err) {
+		return status.Error(codes.Unavailable, "database unavailable")
+	}
+	log.Printf("grpc: %s failed: %v", action, err)
+	return status.Errorf(codes.Internal, "failed to %s", action)
+}
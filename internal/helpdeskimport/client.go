@@ -0,0 +1,152 @@
+package helpdeskimport
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// errTooLarge is returned for attachments over the size limit.
+var errTooLarge = errors.New("attachment is larger than the limit")
+
+// client is the HTTP client of the sources. It waits out rate limits and
+// retries server errors.
+type client struct {
+	http *http.Client
+	// host receives the credentials; attachment URLs on other hosts (file
+	// storage) are fetched without them.
+	host    string
+	auth    func(*http.Request)
+	retries int
+	// sleep waits between attempts; tests replace it.
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+func newClient(base string, auth func(*http.Request)) *client {
+	u, _ := url.Parse(base)
+	host := ""
+	if u != nil {
+		host = u.Host
+	}
+	return &client{
+		http:    &http.Client{Timeout: 2 * time.Minute},
+		host:    host,
+		auth:    auth,
+		retries: 8,
+		sleep:   sleepContext,
+	}
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// get sends a GET request. 429 responses are retried after the
+// Retry-After delay and server errors with exponential backoff.
+func (c *client) get(ctx context.Context, rawURL string) (*http.Response, error) {
+	backoff := time.Second
+	for attempt := 0; ; attempt++ {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Accept", "application/json")
+		if req.URL.Host == c.host {
+			c.auth(req)
+		}
+		resp, err := c.http.Do(req)
+		var wait time.Duration
+		switch {
+		case err != nil:
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			wait = backoff
+		case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+			_ = resp.Body.Close()
+			err = fmt.Errorf("GET %s: %s", req.URL.Path, resp.Status)
+			wait = retryAfter(resp.Header, backoff)
+		case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+			_ = resp.Body.Close()
+			return nil, ErrUnauthorized
+		case resp.StatusCode >= 400:
+			body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+			_ = resp.Body.Close()
+			return nil, fmt.Errorf("GET %s: %s: %s", req.URL.Path, resp.Status, strings.TrimSpace(string(body)))
+		default:
+			return resp, nil
+		}
+		if attempt >= c.retries {
+			return nil, err
+		}
+		if err := c.sleep(ctx, wait); err != nil {
+			return nil, err
+		}
+		backoff = min(2*backoff, time.Minute)
+	}
+}
+
+// getJSON decodes the response to a GET request into v.
+func (c *client) getJSON(ctx context.Context, rawURL string, v any) (http.Header, error) {
+	resp, err := c.get(ctx, rawURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return nil, fmt.Errorf("decode %s: %w", resp.Request.URL.Path, err)
+	}
+	return resp.Header, nil
+}
+
+func (c *client) download(ctx context.Context, rawURL string, max int64) ([]byte, error) {
+	resp, err := c.get(ctx, rawURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if max > 0 && resp.ContentLength > max {
+		return nil, errTooLarge
+	}
+	limit := max
+	if limit <= 0 {
+		limit = 1 << 40
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, errTooLarge
+	}
+	return data, nil
+}
+
+// retryAfter returns the delay a 429 or 503 response asks for, or def.
+func retryAfter(h http.Header, def time.Duration) time.Duration {
+	v := h.Get("Retry-After")
+	if v == "" {
+		return def
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second
+	}
+	if at, err := http.ParseTime(v); err == nil {
+		return max(time.Until(at), 0)
+	}
+	return def
+}
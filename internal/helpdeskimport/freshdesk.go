@@ -0,0 +1,253 @@
+package helpdeskimport
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Freshdesk reads a Freshdesk account with an API key.
+type Freshdesk struct {
+	domain string
+	base   string
+	c      *client
+	groups map[int64]string
+}
+
+// NewFreshdesk creates a source for https://<domain>.freshdesk.com, or for
+// domain itself when it is a host name.
+func NewFreshdesk(domain, apiKey string) *Freshdesk {
+	host := domain
+	if !strings.Contains(host, ".") {
+		host += ".freshdesk.com"
+	}
+	return newFreshdesk(domain, "https://"+host, apiKey)
+}
+
+func newFreshdesk(domain, base, apiKey string) *Freshdesk {
+	return &Freshdesk{
+		domain: domain,
+		base:   base,
+		c: newClient(base, func(r *http.Request) {
+			r.SetBasicAuth(apiKey, "X")
+		}),
+	}
+}
+
+// Name implements Source.
+func (f *Freshdesk) Name() string { return "freshdesk" }
+
+// Account implements Source.
+func (f *Freshdesk) Account() string { return f.domain }
+
+// fdStatuses are the built-in Freshdesk statuses; custom ones count as
+// open.
+var fdStatuses = map[int]string{2: StatusOpen, 3: StatusPending, 4: StatusSolved, 5: StatusClosed}
+
+var fdPriorities = map[int]string{1: PriorityLow, 2: PriorityNormal, 3: PriorityHigh, 4: PriorityUrgent}
+
+type fdAttachment struct {
+	Name          string `json:"name"`
+	ContentType   string `json:"content_type"`
+	Size          int64  `json:"size"`
+	AttachmentURL string `json:"attachment_url"`
+}
+
+func (a fdAttachment) attachment() Attachment {
+	return Attachment{FileName: a.Name, ContentType: a.ContentType, Size: a.Size, URL: a.AttachmentURL}
+}
+
+// each reads all pages of a list endpoint, following the Link header.
+func each[T any](ctx context.Context, f *Freshdesk, next string, fn func(T) error) error {
+	for next != "" {
+		var items []T
+		header, err := f.c.getJSON(ctx, next, &items)
+		if err != nil {
+			return err
+		}
+		for _, item := range items {
+			if err := fn(item); err != nil {
+				return err
+			}
+		}
+		next = nextLink(header.Get("Link"))
+	}
+	return nil
+}
+
+// nextLink returns the rel="next" URL of a Link header.
+func nextLink(link string) string {
+	for _, part := range strings.Split(link, ",") {
+		target, params, ok := strings.Cut(part, ";")
+		if ok && strings.Contains(params, `rel="next"`) {
+			return strings.Trim(strings.TrimSpace(target), "<>")
+		}
+	}
+	return ""
+}
+
+// Organizations implements Source with the companies.
+func (f *Freshdesk) Organizations(ctx context.Context, fn func(Organization) error) error {
+	type company struct {
+		ID   int64  `json:"id"`
+		Name string `json:"name"`
+	}
+	return each(ctx, f, f.base+"/api/v2/companies?per_page=100", func(c company) error {
+		return fn(Organization{ID: strconv.FormatInt(c.ID, 10), Name: c.Name})
+	})
+}
+
+// Users implements Source with the contacts followed by the agents.
+func (f *Freshdesk) Users(ctx context.Context, fn func(User) error) error {
+	type contact struct {
+		ID        int64  `json:"id"`
+		Name      string `json:"name"`
+		Email     string `json:"email"`
+		Phone     string `json:"phone"`
+		Mobile    string `json:"mobile"`
+		CompanyID *int64 `json:"company_id"`
+	}
+	err := each(ctx, f, f.base+"/api/v2/contacts?per_page=100", func(c contact) error {
+		phone := c.Phone
+		if phone == "" {
+			phone = c.Mobile
+		}
+		return fn(User{ID: strconv.FormatInt(c.ID, 10), Name: c.Name, Email: c.Email, Phone: phone,
+			OrganizationID: idString(c.CompanyID)})
+	})
+	if err != nil {
+		return err
+	}
+	type agent struct {
+		ID      int64 `json:"id"`
+		Contact struct {
+			Name  string `json:"name"`
+			Email string `json:"email"`
+		} `json:"contact"`
+	}
+	return each(ctx, f, f.base+"/api/v2/agents?per_page=100", func(a agent) error {
+		return fn(User{ID: strconv.FormatInt(a.ID, 10), Name: a.Contact.Name, Email: a.Contact.Email, Agent: true})
+	})
+}
+
+// Tickets implements Source.
+func (f *Freshdesk) Tickets(ctx context.Context, since time.Time, fn func(Ticket) error) error {
+	if err := f.loadGroups(ctx); err != nil {
+		return err
+	}
+	type ticket struct {
+		ID              int64          `json:"id"`
+		Subject         string         `json:"subject"`
+		Status          int            `json:"status"`
+		Priority        int            `json:"priority"`
+		GroupID         *int64         `json:"group_id"`
+		RequesterID     *int64         `json:"requester_id"`
+		ResponderID     *int64         `json:"responder_id"`
+		CompanyID       *int64         `json:"company_id"`
+		CreatedAt       time.Time      `json:"created_at"`
+		UpdatedAt       time.Time      `json:"updated_at"`
+		DescriptionText string         `json:"description_text"`
+		Attachments     []fdAttachment `json:"attachments"`
+	}
+	// Without updated_since only the last 30 days are listed
+	from := since
+	if from.IsZero() {
+		from = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	first := fmt.Sprintf("%s/api/v2/tickets?per_page=100&include=description&order_by=updated_at&order_type=asc&updated_since=%s",
+		f.base, url.QueryEscape(from.UTC().Format(time.RFC3339)))
+	return each(ctx, f, first, func(t ticket) error {
+		if !t.UpdatedAt.After(since) {
+			return nil
+		}
+		status, ok := fdStatuses[t.Status]
+		if !ok {
+			status = StatusOpen
+		}
+		result := Ticket{
+			ID:             strconv.FormatInt(t.ID, 10),
+			Subject:        t.Subject,
+			Status:         status,
+			Priority:       fdPriorities[t.Priority],
+			RequesterID:    idString(t.RequesterID),
+			AssigneeID:     idString(t.ResponderID),
+			OrganizationID: idString(t.CompanyID),
+			CreatedAt:      t.CreatedAt,
+			UpdatedAt:      t.UpdatedAt,
+			Description:    t.DescriptionText,
+		}
+		if t.GroupID != nil {
+			result.Group = f.groups[*t.GroupID]
+		}
+		for _, a := range t.Attachments {
+			result.Attachments = append(result.Attachments, a.attachment())
+		}
+		return fn(result)
+	})
+}
+
+func (f *Freshdesk) loadGroups(ctx context.Context) error {
+	if f.groups != nil {
+		return nil
+	}
+	type group struct {
+		ID   int64  `json:"id"`
+		Name string `json:"name"`
+	}
+	groups := map[int64]string{}
+	err := each(ctx, f, f.base+"/api/v2/groups?per_page=100", func(g group) error {
+		groups[g.ID] = g.Name
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	f.groups = groups
+	return nil
+}
+
+// Comments implements Source. The ticket description comes first, followed
+// by the conversations.
+func (f *Freshdesk) Comments(ctx context.Context, t Ticket) ([]Comment, error) {
+	comments := []Comment{{
+		ID:          "ticket-" + t.ID,
+		AuthorID:    t.RequesterID,
+		Body:        t.Description,
+		Public:      true,
+		CreatedAt:   t.CreatedAt,
+		Attachments: t.Attachments,
+	}}
+	type conversation struct {
+		ID          int64          `json:"id"`
+		BodyText    string         `json:"body_text"`
+		Private     bool           `json:"private"`
+		UserID      int64          `json:"user_id"`
+		CreatedAt   time.Time      `json:"created_at"`
+		Attachments []fdAttachment `json:"attachments"`
+	}
+	first := fmt.Sprintf("%s/api/v2/tickets/%s/conversations?per_page=100", f.base, url.PathEscape(t.ID))
+	err := each(ctx, f, first, func(c conversation) error {
+		comment := Comment{
+			ID:        strconv.FormatInt(c.ID, 10),
+			AuthorID:  strconv.FormatInt(c.UserID, 10),
+			Body:      c.BodyText,
+			Public:    !c.Private,
+			CreatedAt: c.CreatedAt,
+		}
+		for _, a := range c.Attachments {
+			comment.Attachments = append(comment.Attachments, a.attachment())
+		}
+		comments = append(comments, comment)
+		return nil
+	})
+	return comments, err
+}
+
+// Download implements Source.
+func (f *Freshdesk) Download(ctx context.Context, url string, max int64) ([]byte, error) {
+	return f.c.download(ctx, url, max)
+}
@@ -0,0 +1,112 @@
+// Package helpdeskimport pulls organizations, users, tickets and comments
+// from hosted helpdesks through their REST APIs and creates the matching
+// customer companies, customer users, tickets and articles in GoatFlow.
+//
+// Each helpdesk is a Source that turns its API into the records below. The
+// Importer maps them by name (groups to queues, statuses to states) and
+// records every created row in helpdesk_import_map, so a later run only
+// adds tickets and comments that are new. Runs are tracked in
+// helpdesk_import_run for the admin progress page.
+package helpdeskimport
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	// ErrUnauthorized is returned when the helpdesk rejects the credentials.
+	ErrUnauthorized = errors.New("helpdeskimport: the helpdesk rejected the credentials")
+	// ErrNoQueue is returned when the default queue does not exist.
+	ErrNoQueue = errors.New("helpdeskimport: default queue not found")
+)
+
+// Normalized ticket statuses; sources map their own onto these.
+const (
+	StatusNew     = "new"
+	StatusOpen    = "open"
+	StatusPending = "pending"
+	StatusHold    = "hold"
+	StatusSolved  = "solved"
+	StatusClosed  = "closed"
+)
+
+// Normalized ticket priorities.
+const (
+	PriorityLow    = "low"
+	PriorityNormal = "normal"
+	PriorityHigh   = "high"
+	PriorityUrgent = "urgent"
+)
+
+// Organization is a customer company.
+type Organization struct {
+	ID   string
+	Name string
+}
+
+// User is an end user or an agent of the helpdesk.
+type User struct {
+	ID             string
+	Name           string
+	Email          string
+	Phone          string
+	OrganizationID string
+	Agent          bool
+}
+
+// Ticket is a helpdesk ticket. Group is the name of the group or team the
+// ticket is assigned to.
+type Ticket struct {
+	ID             string
+	Subject        string
+	Status         string
+	Priority       string
+	Group          string
+	RequesterID    string
+	AssigneeID     string
+	OrganizationID string
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	// Description and Attachments hold the first message for helpdesks
+	// that keep it on the ticket rather than as a comment.
+	Description string
+	Attachments []Attachment
+}
+
+// Comment is a message on a ticket; private comments are internal notes.
+type Comment struct {
+	ID          string
+	AuthorID    string
+	Body        string
+	Public      bool
+	CreatedAt   time.Time
+	Attachments []Attachment
+}
+
+// Attachment is a file on a comment, downloaded from URL.
+type Attachment struct {
+	FileName    string
+	ContentType string
+	Size        int64
+	URL         string
+}
+
+// Source reads a helpdesk account.
+type Source interface {
+	// Name is the kind of helpdesk, such as "zendesk".
+	Name() string
+	// Account identifies the helpdesk instance.
+	Account() string
+	Organizations(ctx context.Context, fn func(Organization) error) error
+	// Users yields end users and agents.
+	Users(ctx context.Context, fn func(User) error) error
+	// Tickets yields the tickets updated after since, oldest change first.
+	Tickets(ctx context.Context, since time.Time, fn func(Ticket) error) error
+	// Comments returns the messages of a ticket, oldest first.
+	Comments(ctx context.Context, t Ticket) ([]Comment, error)
+	// Download fetches an attachment, failing when it is larger than max
+	// bytes.
+	Download(ctx context.Context, url string, max int64) ([]byte, error)
+}
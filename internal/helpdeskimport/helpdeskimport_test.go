@@ -0,0 +1,240 @@
+package helpdeskimport
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/testutil"
+)
+
+var created = time.Date(2026, 9, 1, 8, 0, 0, 0, time.UTC)
+
+func count(t *testing.T, db *sql.DB, query string, args ...any) int {
+	t.Helper()
+	var n int
+	require.NoError(t, db.QueryRow(query, args...).Scan(&n), query)
+	return n
+}
+
+// targetDB returns a migrated database with the admin and one agent.
+func targetDB(t *testing.T) *sql.DB {
+	db := testutil.UseMigratedDB(t)
+	for id, login := range map[int]string{1: "root@localhost", 2: "agent@example.com"} {
+		_, err := db.Exec(`INSERT INTO users (id, login, pw, first_name, last_name, valid_id, create_time, create_by, change_time, change_by)
+			VALUES (?, ?, 'x', 'A', 'B', 1, ?, 1, ?, 1)`, id, login, created, created)
+		require.NoError(t, err)
+	}
+	return db
+}
+
+func ticketNumbers() func(context.Context) (string, error) {
+	var n atomic.Int64
+	return func(context.Context) (string, error) {
+		return fmt.Sprintf("2026%06d", n.Add(1)), nil
+	}
+}
+
+func noSleep(waits *[]time.Duration) func(context.Context, time.Duration) error {
+	return func(_ context.Context, d time.Duration) error {
+		*waits = append(*waits, d)
+		return nil
+	}
+}
+
+// zendeskServer fakes the Zendesk API. The ticket export is rate limited
+// once; comments lists the comments of ticket 100.
+func zendeskServer(t *testing.T, comments *[]string) *httptest.Server {
+	var limited atomic.Bool
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v2/organizations.json", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"organizations":[{"id":1,"name":"Acme"}],"meta":{"has_more":false}}`)
+	})
+	mux.HandleFunc("/api/v2/users.json", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"users":[
+			{"id":10,"name":"Alice Smith","email":"alice@acme.test","organization_id":1,"role":"end-user"},
+			{"id":11,"name":"Agent","email":"agent@example.com","role":"agent"},
+			{"id":12,"name":"Nobody","email":"","role":"end-user"}],"meta":{"has_more":false}}`)
+	})
+	mux.HandleFunc("/api/v2/groups.json", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"groups":[{"id":5,"name":"Misc"}],"meta":{"has_more":false}}`)
+	})
+	mux.HandleFunc("/api/v2/incremental/tickets/cursor.json", func(w http.ResponseWriter, r *http.Request) {
+		if !limited.Swap(true) {
+			w.Header().Set("Retry-After", "2")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		fmt.Fprint(w, `{"tickets":[
+			{"id":100,"subject":"Printer","status":"pending","priority":"high","group_id":5,"requester_id":10,
+			 "assignee_id":11,"organization_id":1,"created_at":"2026-09-01T08:00:00Z","updated_at":"2026-09-02T08:00:00Z"},
+			{"id":101,"subject":"Gone","status":"deleted","created_at":"2026-09-01T08:00:00Z","updated_at":"2026-09-02T08:00:00Z"}],
+			"end_of_stream":true}`)
+	})
+	mux.HandleFunc("/api/v2/tickets/100/comments.json", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"comments":[%s],"meta":{"has_more":false}}`, strings.Join(*comments, ","))
+	})
+	mux.HandleFunc("/files/log.txt", func(w http.ResponseWriter, r *http.Request) {
+		_, _, ok := r.BasicAuth()
+		assert.True(t, ok, "attachments on the API host are fetched with credentials")
+		fmt.Fprint(w, "hello")
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestImportZendesk(t *testing.T) {
+	db := targetDB(t)
+	var srv *httptest.Server
+	comments := []string{}
+	srv = zendeskServer(t, &comments)
+	comments = append(comments,
+		`{"id":1000,"author_id":10,"plain_body":"It is broken","public":true,"created_at":"2026-09-01T08:00:00Z",
+		  "attachments":[{"file_name":"log.txt","content_url":"`+srv.URL+`/files/log.txt","content_type":"text/plain","size":5}]}`,
+		`{"id":1001,"author_id":11,"body":"Checking","public":false,"created_at":"2026-09-01T09:00:00Z"}`)
+
+	var waits []time.Duration
+	run := func() Progress {
+		src := newZendesk("acme", srv.URL, "agent@example.com", "token")
+		src.c.sleep = noSleep(&waits)
+		p, err := New(db, src, Options{NextTicketNumber: ticketNumbers()}).Run(context.Background())
+		require.NoError(t, err)
+		return p
+	}
+
+	p := run()
+	assert.Equal(t, []time.Duration{2 * time.Second}, waits, "the rate limited request waits for Retry-After")
+	assert.Equal(t, 1, p.Organizations)
+	assert.Equal(t, 1, p.Users)
+	assert.Equal(t, 1, p.Tickets)
+	assert.Equal(t, 2, p.Articles)
+	assert.Equal(t, 1, p.Attachments)
+	assert.Equal(t, 1, p.Skipped, "the user without email is skipped")
+
+	assert.Equal(t, 1, count(t, db, "SELECT COUNT(*) FROM customer_company WHERE customer_id = 'Acme'"))
+	assert.Equal(t, 1, count(t, db, "SELECT COUNT(*) FROM customer_user WHERE login = 'alice@acme.test' AND customer_id = 'Acme' AND first_name = 'Alice' AND last_name = 'Smith'"))
+	var queue, owner int
+	var state, customerUser, priority string
+	require.NoError(t, db.QueryRow(`SELECT t.queue_id, s.name, t.user_id, t.customer_user_id, p.name FROM ticket t
+		JOIN ticket_state s ON s.id = t.ticket_state_id JOIN ticket_priority p ON p.id = t.ticket_priority_id
+		WHERE t.title = 'Printer'`).Scan(&queue, &state, &owner, &customerUser, &priority))
+	assert.Equal(t, 4, queue, "group Misc maps to queue Misc")
+	assert.Equal(t, "pending reminder", state)
+	assert.Equal(t, 2, owner)
+	assert.Equal(t, "alice@acme.test", customerUser)
+	assert.Equal(t, "4 high", priority)
+	assert.Equal(t, 1, count(t, db, "SELECT COUNT(*) FROM article WHERE is_visible_for_customer = 0 AND create_by = 2"))
+	var content []byte
+	require.NoError(t, db.QueryRow("SELECT content FROM article_data_mime_attachment WHERE filename = 'log.txt'").Scan(&content))
+	assert.Equal(t, "hello", string(content))
+
+	// A second run only adds the new comment.
+	comments = append(comments, `{"id":1002,"author_id":10,"plain_body":"Thanks","public":true,"created_at":"2026-09-03T08:00:00Z"}`)
+	p = run()
+	assert.Equal(t, 0, p.Organizations+p.Users+p.Tickets)
+	assert.Equal(t, 1, p.Articles)
+	assert.Equal(t, 1, count(t, db, "SELECT COUNT(*) FROM ticket"))
+	assert.Equal(t, 3, count(t, db, "SELECT COUNT(*) FROM article"))
+
+	runs, err := ListRuns(context.Background(), db, 10)
+	require.NoError(t, err)
+	require.Len(t, runs, 2)
+	assert.Equal(t, RunCompleted, runs[0].Status)
+	assert.Equal(t, PhaseDone, runs[0].Phase)
+	last, err := LastCompleted(context.Background(), db, "zendesk", "acme")
+	require.NoError(t, err)
+	assert.Equal(t, runs[0].ID, last.ID)
+}
+
+func TestImportFreshdesk(t *testing.T) {
+	db := targetDB(t)
+	var srv *httptest.Server
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v2/companies", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[{"id":1,"name":"Acme"}]`)
+	})
+	mux.HandleFunc("/api/v2/contacts", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[{"id":10,"name":"Bob","email":"bob@acme.test","mobile":"555","company_id":1}]`)
+	})
+	mux.HandleFunc("/api/v2/agents", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[{"id":11,"contact":{"name":"Unknown","email":"unknown@example.com"}}]`)
+	})
+	mux.HandleFunc("/api/v2/groups", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[]`)
+	})
+	mux.HandleFunc("/api/v2/tickets", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("page") == "" {
+			w.Header().Set("Link", `<`+srv.URL+`/api/v2/tickets?page=2>; rel="next"`)
+			fmt.Fprint(w, `[{"id":1,"subject":"First","status":2,"priority":1,"requester_id":10,"responder_id":11,
+				"created_at":"2026-09-01T08:00:00Z","updated_at":"2026-09-01T08:00:00Z","description_text":"Hi",
+				"attachments":[{"name":"big.bin","content_type":"application/octet-stream","size":10,"attachment_url":"`+srv.URL+`/big"}]}]`)
+			return
+		}
+		fmt.Fprint(w, `[{"id":2,"subject":"Second","status":5,"priority":4,"requester_id":10,
+			"created_at":"2026-09-01T08:00:00Z","updated_at":"2026-09-02T08:00:00Z","description_text":"Hello"}]`)
+	})
+	mux.HandleFunc("/api/v2/tickets/1/conversations", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[{"id":500,"body_text":"On it","private":true,"user_id":11,"created_at":"2026-09-01T09:00:00Z"}]`)
+	})
+	mux.HandleFunc("/api/v2/tickets/2/conversations", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[]`)
+	})
+	srv = httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	src := newFreshdesk("acme", srv.URL, "key")
+	p, err := New(db, src, Options{NextTicketNumber: ticketNumbers(), MaxAttachmentSize: 4}).Run(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 2, p.Tickets)
+	assert.Equal(t, 3, p.Articles, "descriptions become the first article")
+	assert.Equal(t, 0, p.Attachments)
+	assert.Equal(t, 1, p.Skipped, "the attachment over the limit is skipped")
+	require.Len(t, p.Issues, 2)
+	assert.Contains(t, p.Issues[0], "unknown@example.com")
+
+	assert.Equal(t, 1, count(t, db, "SELECT COUNT(*) FROM customer_user WHERE login = 'bob@acme.test' AND phone = '555'"))
+	assert.Equal(t, 1, count(t, db, `SELECT COUNT(*) FROM ticket t JOIN ticket_state s ON s.id = t.ticket_state_id
+		WHERE t.title = 'Second' AND s.name = 'closed successful' AND t.queue_id = 2`))
+	assert.Equal(t, 1, count(t, db, "SELECT COUNT(*) FROM article_data_mime WHERE a_body = 'Hi' AND a_from = 'Bob <bob@acme.test>'"))
+}
+
+func TestImportNoQueue(t *testing.T) {
+	db := targetDB(t)
+	src := newFreshdesk("acme", "http://127.0.0.1:1", "key")
+	_, err := New(db, src, Options{NextTicketNumber: ticketNumbers(), DefaultQueue: "Nope"}).Run(context.Background())
+	assert.ErrorIs(t, err, ErrNoQueue)
+}
+
+func TestUnauthorized(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+	err := newZendesk("acme", srv.URL, "a@b.c", "bad").Organizations(context.Background(), func(Organization) error { return nil })
+	assert.ErrorIs(t, err, ErrUnauthorized)
+}
+
+func TestNextLink(t *testing.T) {
+	assert.Equal(t, "https://x.test/a?page=2",
+		nextLink(`<https://x.test/a?page=1>; rel="prev", <https://x.test/a?page=2>; rel="next"`))
+	assert.Equal(t, "", nextLink(""))
+}
+
+func TestRetryAfter(t *testing.T) {
+	h := http.Header{}
+	assert.Equal(t, time.Second, retryAfter(h, time.Second))
+	h.Set("Retry-After", "30")
+	assert.Equal(t, 30*time.Second, retryAfter(h, time.Second))
+	h.Set("Retry-After", "soon")
+	assert.Equal(t, time.Second, retryAfter(h, time.Second))
+}
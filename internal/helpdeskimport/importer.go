@@ -0,0 +1,673 @@
+package helpdeskimport
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goatkit/goatflow/internal/database"
+)
+
+// Defaults for Options.
+const (
+	DefaultQueue             = "Raw"
+	DefaultMaxAttachmentSize = 20 << 20
+)
+
+// Options configure an import run.
+type Options struct {
+	// Since limits the run to tickets updated after it; zero imports all.
+	Since time.Time
+	// DefaultQueue receives tickets whose group has no queue of the same
+	// name.
+	DefaultQueue string
+	// MaxAttachmentSize skips larger attachments with an issue.
+	MaxAttachmentSize int64
+	// NextTicketNumber returns the number of a new ticket.
+	NextTicketNumber func(ctx context.Context) (string, error)
+	// Progress is called after every imported ticket and phase.
+	Progress func(Progress)
+}
+
+// Importer copies a helpdesk account into the GoatFlow database.
+type Importer struct {
+	db   *sql.DB
+	src  Source
+	opts Options
+	now  func() time.Time
+
+	runID    int64
+	progress Progress
+
+	states     map[string]int
+	priorities map[string]int
+	queues     map[string]int
+	senders    map[string]int
+	channels   map[string]int
+	unlock     int
+
+	orgs  map[string]string
+	users map[string]person
+}
+
+// person is an imported user: an agent with a GoatFlow user ID or a
+// customer user with a login.
+type person struct {
+	agentID    int
+	login      string
+	customerID string
+	name       string
+	email      string
+}
+
+// New creates an importer writing to db.
+func New(db *sql.DB, src Source, opts Options) *Importer {
+	if opts.DefaultQueue == "" {
+		opts.DefaultQueue = DefaultQueue
+	}
+	if opts.MaxAttachmentSize <= 0 {
+		opts.MaxAttachmentSize = DefaultMaxAttachmentSize
+	}
+	return &Importer{
+		db:    db,
+		src:   src,
+		opts:  opts,
+		now:   time.Now,
+		orgs:  map[string]string{},
+		users: map[string]person{},
+	}
+}
+
+// Run imports the organizations, users and tickets changed since
+// Options.Since and returns what was created. The run is recorded in
+// helpdesk_import_run whether it succeeds or not.
+func (im *Importer) Run(ctx context.Context) (Progress, error) {
+	if im.opts.NextTicketNumber == nil {
+		return im.progress, errors.New("helpdeskimport: NextTicketNumber is required")
+	}
+	if err := im.loadLookups(ctx); err != nil {
+		return im.progress, err
+	}
+	id, err := startRun(ctx, im.db, im.src, im.opts.Since, im.now())
+	if err != nil {
+		return im.progress, err
+	}
+	im.runID = id
+	im.progress = Progress{Phase: PhaseOrganizations}
+
+	err = im.run(ctx)
+	status, lastError := RunCompleted, ""
+	if err != nil {
+		status, lastError = RunFailed, err.Error()
+	} else {
+		im.progress.Phase = PhaseDone
+	}
+	// The run context may be canceled; the final status is still written.
+	if serr := saveRun(context.WithoutCancel(ctx), im.db, im.runID, status, &im.progress, lastError, im.now()); serr != nil && err == nil {
+		err = serr
+	}
+	im.report()
+	return im.progress, err
+}
+
+func (im *Importer) run(ctx context.Context) error {
+	if err := im.src.Organizations(ctx, func(o Organization) error {
+		return im.importOrganization(ctx, o)
+	}); err != nil {
+		return fmt.Errorf("organizations: %w", err)
+	}
+	if err := im.phase(ctx, PhaseUsers); err != nil {
+		return err
+	}
+	if err := im.src.Users(ctx, func(u User) error {
+		return im.importUser(ctx, u)
+	}); err != nil {
+		return fmt.Errorf("users: %w", err)
+	}
+	if err := im.phase(ctx, PhaseTickets); err != nil {
+		return err
+	}
+	if err := im.src.Tickets(ctx, im.opts.Since, func(t Ticket) error {
+		if err := im.importTicket(ctx, t); err != nil {
+			return fmt.Errorf("ticket %s: %w", t.ID, err)
+		}
+		return im.checkpoint(ctx)
+	}); err != nil {
+		return fmt.Errorf("tickets: %w", err)
+	}
+	return nil
+}
+
+func (im *Importer) phase(ctx context.Context, phase string) error {
+	im.progress.Phase = phase
+	return im.checkpoint(ctx)
+}
+
+// checkpoint saves the progress for the admin page.
+func (im *Importer) checkpoint(ctx context.Context) error {
+	if err := saveRun(ctx, im.db, im.runID, RunRunning, &im.progress, "", im.now()); err != nil {
+		return err
+	}
+	im.report()
+	return nil
+}
+
+func (im *Importer) report() {
+	if im.opts.Progress != nil {
+		im.opts.Progress(im.progress)
+	}
+}
+
+func (im *Importer) loadLookups(ctx context.Context) error {
+	var err error
+	load := func(table string) map[string]int {
+		if err != nil {
+			return nil
+		}
+		var m map[string]int
+		m, err = names(ctx, im.db, table)
+		return m
+	}
+	im.states = load("ticket_state")
+	im.priorities = load("ticket_priority")
+	im.queues = load("queue")
+	im.senders = load("article_sender_type")
+	im.channels = load("communication_channel")
+	locks := load("ticket_lock_type")
+	if err != nil {
+		return err
+	}
+	if _, ok := im.queues[im.opts.DefaultQueue]; !ok {
+		return fmt.Errorf("%w: %s", ErrNoQueue, im.opts.DefaultQueue)
+	}
+	im.unlock = locks["unlock"]
+	for _, required := range []struct {
+		m    map[string]int
+		name string
+	}{
+		{im.states, "new"}, {im.states, "open"}, {im.states, "pending reminder"}, {im.states, "closed successful"},
+		{im.priorities, "3 normal"}, {im.senders, "agent"}, {im.senders, "customer"},
+		{im.channels, "Email"}, {im.channels, "Internal"},
+	} {
+		if _, ok := required.m[required.name]; !ok {
+			return fmt.Errorf("helpdeskimport: %q is missing from the database", required.name)
+		}
+	}
+	return nil
+}
+
+// names maps the names of a lookup table to their IDs.
+func names(ctx context.Context, db *sql.DB, table string) (map[string]int, error) {
+	rows, err := db.QueryContext(ctx, "SELECT id, name FROM "+table)
+	if err != nil {
+		return nil, fmt.Errorf("load %s: %w", table, err)
+	}
+	defer rows.Close()
+	m := map[string]int{}
+	for rows.Next() {
+		var id int
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			return nil, err
+		}
+		m[name] = id
+	}
+	return m, rows.Err()
+}
+
+func (im *Importer) importOrganization(ctx context.Context, o Organization) error {
+	if _, key, ok, err := im.mapped(ctx, im.db, "organization", o.ID); err != nil || ok {
+		im.orgs[o.ID] = key
+		return err
+	}
+	name := truncate(strings.TrimSpace(o.Name), 200)
+	if name == "" {
+		im.progress.skip("organization %s has no name", o.ID)
+		return nil
+	}
+	var customerID string
+	err := im.db.QueryRowContext(ctx, database.ConvertPlaceholders(
+		"SELECT customer_id FROM customer_company WHERE name = ?"), name).Scan(&customerID)
+	if errors.Is(err, sql.ErrNoRows) {
+		customerID, err = im.createCompany(ctx, o, name)
+	}
+	if err != nil {
+		return fmt.Errorf("organization %s: %w", o.ID, err)
+	}
+	im.orgs[o.ID] = customerID
+	return im.remember(ctx, im.db, "organization", o.ID, 0, customerID)
+}
+
+func (im *Importer) createCompany(ctx context.Context, o Organization, name string) (string, error) {
+	customerID := truncate(name, 150)
+	var taken int
+	err := im.db.QueryRowContext(ctx, database.ConvertPlaceholders(
+		"SELECT COUNT(*) FROM customer_company WHERE customer_id = ?"), customerID).Scan(&taken)
+	if err != nil {
+		return "", err
+	}
+	if taken > 0 {
+		customerID = im.src.Name() + "-" + o.ID
+	}
+	now := im.now()
+	_, err = im.db.ExecContext(ctx, database.ConvertPlaceholders(`
+		INSERT INTO customer_company (customer_id, name, valid_id, create_time, create_by, change_time, change_by)
+		VALUES (?, ?, 1, ?, 1, ?, 1)`), customerID, name, now, now)
+	if err != nil {
+		return "", err
+	}
+	im.progress.Organizations++
+	return customerID, nil
+}
+
+func (im *Importer) importUser(ctx context.Context, u User) error {
+	email := strings.TrimSpace(u.Email)
+	if u.Agent {
+		return im.matchAgent(ctx, u, email)
+	}
+	if _, login, ok, err := im.mapped(ctx, im.db, "user", u.ID); err != nil {
+		return err
+	} else if ok {
+		var customerID string
+		err := im.db.QueryRowContext(ctx, database.ConvertPlaceholders(
+			"SELECT customer_id FROM customer_user WHERE login = ?"), login).Scan(&customerID)
+		if err == nil {
+			im.users[u.ID] = person{login: login, customerID: customerID, name: u.Name, email: email}
+			return nil
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		// The customer user was deleted since; create it again.
+	}
+	if email == "" {
+		im.progress.skip("user %s (%s) has no email address", u.ID, u.Name)
+		return nil
+	}
+	p := person{login: truncate(email, 200), name: u.Name, email: email}
+	err := im.db.QueryRowContext(ctx, database.ConvertPlaceholders(
+		"SELECT login, customer_id FROM customer_user WHERE LOWER(login) = LOWER(?)"), p.login).
+		Scan(&p.login, &p.customerID)
+	if errors.Is(err, sql.ErrNoRows) {
+		err = im.createCustomerUser(ctx, u, &p)
+	}
+	if err != nil {
+		return fmt.Errorf("user %s: %w", u.ID, err)
+	}
+	im.users[u.ID] = p
+	return im.remember(ctx, im.db, "user", u.ID, 0, p.login)
+}
+
+func (im *Importer) createCustomerUser(ctx context.Context, u User, p *person) error {
+	p.customerID = im.orgs[u.OrganizationID]
+	if p.customerID == "" {
+		p.customerID = truncate(p.email, 150)
+	}
+	first, last := splitName(u.Name, p.email)
+	var phone any
+	if u.Phone != "" {
+		phone = truncate(u.Phone, 150)
+	}
+	now := im.now()
+	_, err := im.db.ExecContext(ctx, database.ConvertPlaceholders(`
+		INSERT INTO customer_user (login, email, customer_id, first_name, last_name, phone, valid_id,
+			create_time, create_by, change_time, change_by)
+		VALUES (?, ?, ?, ?, ?, ?, 1, ?, 1, ?, 1)`),
+		p.login, truncate(p.email, 150), p.customerID, first, last, phone, now, now)
+	if err != nil {
+		return err
+	}
+	im.progress.Users++
+	return nil
+}
+
+// matchAgent finds the GoatFlow agent whose login is the email address of
+// a helpdesk agent. Agents are never created; the tickets and notes of
+// unmatched agents are owned by the admin user.
+func (im *Importer) matchAgent(ctx context.Context, u User, email string) error {
+	if email == "" {
+		return nil
+	}
+	var id int
+	err := im.db.QueryRowContext(ctx, database.ConvertPlaceholders(
+		"SELECT id FROM users WHERE LOWER(login) = LOWER(?)"), email).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		im.progress.note("agent %s has no GoatFlow user; their tickets are assigned to admin", email)
+		im.users[u.ID] = person{agentID: 1, name: u.Name, email: email}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("agent %s: %w", u.ID, err)
+	}
+	im.users[u.ID] = person{agentID: id, name: u.Name, email: email}
+	return nil
+}
+
+// attachment is a downloaded attachment.
+type attachment struct {
+	Attachment
+	data []byte
+}
+
+// newComment is a comment that is not imported yet.
+type newComment struct {
+	Comment
+	files []attachment
+}
+
+func (im *Importer) importTicket(ctx context.Context, t Ticket) error {
+	ticketID, _, exists, err := im.mapped(ctx, im.db, "ticket", t.ID)
+	if err != nil {
+		return err
+	}
+	comments, err := im.src.Comments(ctx, t)
+	if err != nil {
+		return err
+	}
+	var fresh []newComment
+	for _, c := range comments {
+		_, _, done, err := im.mapped(ctx, im.db, "comment", c.ID)
+		if err != nil {
+			return err
+		}
+		if done {
+			continue
+		}
+		nc := newComment{Comment: c}
+		for _, a := range c.Attachments {
+			data, err := im.download(ctx, t, a)
+			if err != nil {
+				return err
+			}
+			if data != nil {
+				nc.files = append(nc.files, attachment{Attachment: a, data: data})
+			}
+		}
+		fresh = append(fresh, nc)
+	}
+
+	// The ticket number counter has its own transaction, so it is taken
+	// before this one starts.
+	var tn string
+	if !exists {
+		if tn, err = im.opts.NextTicketNumber(ctx); err != nil {
+			return fmt.Errorf("ticket number: %w", err)
+		}
+	}
+	tx, err := im.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+	if exists {
+		err = im.updateTicket(ctx, tx, ticketID, t)
+	} else {
+		ticketID, err = im.insertTicket(ctx, tx, t, tn)
+	}
+	if err != nil {
+		return err
+	}
+	articles, files := 0, 0
+	for _, c := range fresh {
+		articleID, err := im.insertArticle(ctx, tx, ticketID, t, c)
+		if err != nil {
+			return fmt.Errorf("comment %s: %w", c.ID, err)
+		}
+		if err := im.remember(ctx, tx, "comment", c.ID, articleID, ""); err != nil {
+			return err
+		}
+		articles++
+		files += len(c.files)
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	if !exists {
+		im.progress.Tickets++
+	}
+	im.progress.Articles += articles
+	im.progress.Attachments += files
+	return nil
+}
+
+// download fetches an attachment. Attachments that are too large or fail
+// to download are noted and skipped, returning nil data.
+func (im *Importer) download(ctx context.Context, t Ticket, a Attachment) ([]byte, error) {
+	if a.Size > im.opts.MaxAttachmentSize {
+		im.progress.skip("ticket %s: attachment %s is larger than %d bytes", t.ID, a.FileName, im.opts.MaxAttachmentSize)
+		return nil, nil
+	}
+	data, err := im.src.Download(ctx, a.URL, im.opts.MaxAttachmentSize)
+	switch {
+	case errors.Is(err, errTooLarge):
+		im.progress.skip("ticket %s: attachment %s is larger than %d bytes", t.ID, a.FileName, im.opts.MaxAttachmentSize)
+		return nil, nil
+	case err != nil && ctx.Err() != nil:
+		return nil, ctx.Err()
+	case err != nil:
+		im.progress.skip("ticket %s: download %s: %v", t.ID, a.FileName, err)
+		return nil, nil
+	}
+	if data == nil {
+		data = []byte{}
+	}
+	return data, nil
+}
+
+// fields are the ticket columns derived from a helpdesk ticket.
+type fields struct {
+	title        string
+	queue        int
+	state        int
+	priority     int
+	owner        int
+	untilTime    int64
+	customerID   any
+	customerUser any
+}
+
+func (im *Importer) ticketFields(t Ticket) fields {
+	f := fields{
+		title:    truncate(strings.TrimSpace(t.Subject), 255),
+		queue:    im.queues[im.opts.DefaultQueue],
+		priority: im.priorities["3 normal"],
+		owner:    1,
+	}
+	if f.title == "" {
+		f.title = "(no subject)"
+	}
+	if id, ok := im.queues[t.Group]; ok && t.Group != "" {
+		f.queue = id
+	}
+	switch t.Status {
+	case StatusNew:
+		f.state = im.states["new"]
+	case StatusPending, StatusHold:
+		f.state = im.states["pending reminder"]
+		f.untilTime = t.UpdatedAt.Unix()
+	case StatusSolved, StatusClosed:
+		f.state = im.states["closed successful"]
+	default:
+		f.state = im.states["open"]
+	}
+	if name, ok := priorityNames[t.Priority]; ok {
+		if id, ok := im.priorities[name]; ok {
+			f.priority = id
+		}
+	}
+	if p, ok := im.users[t.AssigneeID]; ok && p.agentID > 0 {
+		f.owner = p.agentID
+	}
+	if p, ok := im.users[t.RequesterID]; ok && p.login != "" {
+		f.customerUser = p.login
+		f.customerID = p.customerID
+	}
+	if id, ok := im.orgs[t.OrganizationID]; ok && id != "" {
+		f.customerID = id
+	}
+	return f
+}
+
+var priorityNames = map[string]string{
+	PriorityLow:    "2 low",
+	PriorityNormal: "3 normal",
+	PriorityHigh:   "4 high",
+	PriorityUrgent: "5 very high",
+}
+
+func (im *Importer) insertTicket(ctx context.Context, tx *sql.Tx, t Ticket, tn string) (int64, error) {
+	f := im.ticketFields(t)
+	created, changed := im.times(t)
+	id, err := database.GetAdapter().InsertWithReturningTx(tx, database.ConvertPlaceholders(`
+		INSERT INTO ticket (tn, title, queue_id, ticket_lock_id, user_id, responsible_user_id,
+			ticket_priority_id, ticket_state_id, customer_id, customer_user_id, timeout, until_time,
+			escalation_time, escalation_update_time, escalation_response_time, escalation_solution_time,
+			archive_flag, create_time, create_by, change_time, change_by)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, 0, ?, 0, 0, 0, 0, 0, ?, 1, ?, 1) RETURNING id`),
+		tn, f.title, f.queue, im.unlock, f.owner, f.owner, f.priority, f.state, f.customerID, f.customerUser,
+		f.untilTime, created, changed)
+	if err != nil {
+		return 0, err
+	}
+	return id, im.remember(ctx, tx, "ticket", t.ID, id, tn)
+}
+
+func (im *Importer) updateTicket(ctx context.Context, tx *sql.Tx, id int64, t Ticket) error {
+	f := im.ticketFields(t)
+	_, changed := im.times(t)
+	_, err := tx.ExecContext(ctx, database.ConvertPlaceholders(`
+		UPDATE ticket SET title = ?, queue_id = ?, ticket_state_id = ?, ticket_priority_id = ?, user_id = ?,
+			until_time = ?, change_time = ?, change_by = 1
+		WHERE id = ?`),
+		f.title, f.queue, f.state, f.priority, f.owner, f.untilTime, changed, id)
+	return err
+}
+
+// times returns the create and change time of a ticket, falling back to now.
+func (im *Importer) times(t Ticket) (time.Time, time.Time) {
+	created, changed := t.CreatedAt, t.UpdatedAt
+	if created.IsZero() {
+		created = im.now()
+	}
+	if changed.Before(created) {
+		changed = created
+	}
+	return created, changed
+}
+
+func (im *Importer) insertArticle(ctx context.Context, tx *sql.Tx, ticketID int64, t Ticket, c newComment) (int64, error) {
+	author := im.users[c.AuthorID]
+	sender, createBy := im.senders["customer"], 1
+	if author.agentID > 0 {
+		sender, createBy = im.senders["agent"], author.agentID
+	}
+	channel, visible := im.channels["Email"], 1
+	if !c.Public {
+		channel, visible = im.channels["Internal"], 0
+	}
+	created := c.CreatedAt
+	if created.IsZero() {
+		created, _ = im.times(t)
+	}
+	id, err := database.GetAdapter().InsertWithReturningTx(tx, database.ConvertPlaceholders(`
+		INSERT INTO article (ticket_id, article_sender_type_id, communication_channel_id, is_visible_for_customer,
+			create_time, create_by, change_time, change_by)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?) RETURNING id`),
+		ticketID, sender, channel, visible, created, createBy, created, createBy)
+	if err != nil {
+		return 0, err
+	}
+	from := author.email
+	if author.name != "" && author.email != "" {
+		from = fmt.Sprintf("%s <%s>", author.name, author.email)
+	}
+	_, err = tx.ExecContext(ctx, database.ConvertPlaceholders(`
+		INSERT INTO article_data_mime (article_id, a_from, a_subject, a_body, a_content_type, incoming_time,
+			create_time, create_by, change_time, change_by)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`),
+		id, from, truncate(strings.TrimSpace(t.Subject), 255), c.Body, "text/plain; charset=utf-8", created.Unix(),
+		created, createBy, created, createBy)
+	if err != nil {
+		return 0, err
+	}
+	for _, a := range c.files {
+		contentType := a.ContentType
+		if contentType == "" {
+			contentType = "application/octet-stream"
+		}
+		_, err = tx.ExecContext(ctx, database.ConvertPlaceholders(`
+			INSERT INTO article_data_mime_attachment (article_id, filename, content_type, content_size, content,
+				disposition, create_time, create_by, change_time, change_by)
+			VALUES (?, ?, ?, ?, ?, 'attachment', ?, ?, ?, ?)`),
+			id, truncate(a.FileName, 250), contentType, strconv.Itoa(len(a.data)), a.data,
+			created, createBy, created, createBy)
+		if err != nil {
+			return 0, fmt.Errorf("attachment %s: %w", a.FileName, err)
+		}
+	}
+	return id, nil
+}
+
+type querier interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// mapped looks up a record imported by an earlier run.
+func (im *Importer) mapped(ctx context.Context, q querier, entity, externalID string) (int64, string, bool, error) {
+	var id int64
+	var key sql.NullString
+	err := q.QueryRowContext(ctx, database.ConvertPlaceholders(`
+		SELECT target_id, target_key FROM helpdesk_import_map
+		WHERE source = ? AND account = ? AND entity = ? AND external_id = ?`),
+		im.src.Name(), im.src.Account(), entity, externalID).Scan(&id, &key)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, "", false, nil
+	}
+	if err != nil {
+		return 0, "", false, fmt.Errorf("load import map: %w", err)
+	}
+	return id, key.String, true, nil
+}
+
+// remember records an imported record, replacing an earlier mapping.
+func (im *Importer) remember(ctx context.Context, q querier, entity, externalID string, targetID int64, key string) error {
+	args := []any{im.src.Name(), im.src.Account(), entity, externalID}
+	if _, err := q.ExecContext(ctx, database.ConvertPlaceholders(`
+		DELETE FROM helpdesk_import_map WHERE source = ? AND account = ? AND entity = ? AND external_id = ?`),
+		args...); err != nil {
+		return fmt.Errorf("update import map: %w", err)
+	}
+	_, err := q.ExecContext(ctx, database.ConvertPlaceholders(`
+		INSERT INTO helpdesk_import_map (source, account, entity, external_id, target_id, target_key, imported_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`),
+		append(args, targetID, sql.NullString{String: key, Valid: key != ""}, im.now())...)
+	if err != nil {
+		return fmt.Errorf("update import map: %w", err)
+	}
+	return nil
+}
+
+// splitName splits a display name into first and last name, using the
+// local part of the email address when the name is empty.
+func splitName(name, email string) (string, string) {
+	parts := strings.Fields(name)
+	if len(parts) == 0 {
+		local, _, _ := strings.Cut(email, "@")
+		return truncate(local, 100), ""
+	}
+	return truncate(parts[0], 100), truncate(strings.Join(parts[1:], " "), 100)
+}
+
+// truncate cuts s to at most n runes.
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n])
+}
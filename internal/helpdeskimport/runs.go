@@ -0,0 +1,166 @@
+package helpdeskimport
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/goatkit/goatflow/internal/database"
+)
+
+// Run statuses. A running run that has not reported progress for
+// stallAfter is shown as stalled; its process most likely died.
+const (
+	RunRunning   = "running"
+	RunCompleted = "completed"
+	RunFailed    = "failed"
+	RunStalled   = "stalled"
+)
+
+const stallAfter = 10 * time.Minute
+
+// Import phases.
+const (
+	PhaseOrganizations = "organizations"
+	PhaseUsers         = "users"
+	PhaseTickets       = "tickets"
+	PhaseDone          = "done"
+)
+
+// maxIssues is the number of issues kept per run.
+const maxIssues = 50
+
+// Progress counts what a run created so far.
+type Progress struct {
+	Phase         string   `json:"phase"`
+	Organizations int      `json:"organizations"`
+	Users         int      `json:"users"`
+	Tickets       int      `json:"tickets"`
+	Articles      int      `json:"articles"`
+	Attachments   int      `json:"attachments"`
+	Skipped       int      `json:"skipped"`
+	Issues        []string `json:"issues"`
+}
+
+func (p *Progress) skip(format string, args ...any) {
+	p.Skipped++
+	p.note(format, args...)
+}
+
+func (p *Progress) note(format string, args ...any) {
+	if len(p.Issues) < maxIssues {
+		p.Issues = append(p.Issues, fmt.Sprintf(format, args...))
+	}
+}
+
+// Run is an import run as shown on the admin progress page.
+type Run struct {
+	ID      int64  `json:"id"`
+	Source  string `json:"source"`
+	Account string `json:"account"`
+	Status  string `json:"status"`
+	Progress
+	LastError  string     `json:"last_error,omitempty"`
+	Since      *time.Time `json:"since,omitempty"`
+	StartedAt  time.Time  `json:"started_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+const runColumns = `id, source, account, status, phase, organizations, users, tickets, articles, attachments,
+	skipped, issues, last_error, since, started_at, updated_at, finished_at`
+
+func scanRun(row interface{ Scan(...any) error }) (*Run, error) {
+	var r Run
+	var issues, lastError sql.NullString
+	var since, finished sql.NullTime
+	err := row.Scan(&r.ID, &r.Source, &r.Account, &r.Status, &r.Phase, &r.Organizations, &r.Users, &r.Tickets,
+		&r.Articles, &r.Attachments, &r.Skipped, &issues, &lastError, &since, &r.StartedAt, &r.UpdatedAt, &finished)
+	if err != nil {
+		return nil, err
+	}
+	if issues.String != "" {
+		_ = json.Unmarshal([]byte(issues.String), &r.Issues)
+	}
+	r.LastError = lastError.String
+	if since.Valid {
+		r.Since = &since.Time
+	}
+	if finished.Valid {
+		r.FinishedAt = &finished.Time
+	}
+	if r.Status == RunRunning && time.Since(r.UpdatedAt) > stallAfter {
+		r.Status = RunStalled
+	}
+	return &r, nil
+}
+
+// ListRuns returns the latest runs, newest first.
+func ListRuns(ctx context.Context, db *sql.DB, limit int) ([]Run, error) {
+	rows, err := db.QueryContext(ctx, database.ConvertPlaceholders(
+		"SELECT "+runColumns+" FROM helpdesk_import_run ORDER BY id DESC LIMIT ?"), limit)
+	if err != nil {
+		return nil, fmt.Errorf("list import runs: %w", err)
+	}
+	defer rows.Close()
+	runs := []Run{}
+	for rows.Next() {
+		r, err := scanRun(rows)
+		if err != nil {
+			return nil, err
+		}
+		runs = append(runs, *r)
+	}
+	return runs, rows.Err()
+}
+
+// LastCompleted returns the newest completed run of a helpdesk account, or
+// nil.
+func LastCompleted(ctx context.Context, db *sql.DB, source, account string) (*Run, error) {
+	r, err := scanRun(db.QueryRowContext(ctx, database.ConvertPlaceholders(
+		"SELECT "+runColumns+" FROM helpdesk_import_run WHERE source = ? AND account = ? AND status = ? ORDER BY id DESC LIMIT 1"),
+		source, account, RunCompleted))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("load last import run: %w", err)
+	}
+	return r, nil
+}
+
+func startRun(ctx context.Context, db *sql.DB, src Source, since time.Time, now time.Time) (int64, error) {
+	var sinceArg any
+	if !since.IsZero() {
+		sinceArg = since
+	}
+	id, err := database.GetAdapter().InsertWithReturning(db, database.ConvertPlaceholders(`
+		INSERT INTO helpdesk_import_run (source, account, status, phase, since, started_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?) RETURNING id`),
+		src.Name(), src.Account(), RunRunning, PhaseOrganizations, sinceArg, now, now)
+	if err != nil {
+		return 0, fmt.Errorf("record import run: %w", err)
+	}
+	return id, nil
+}
+
+func saveRun(ctx context.Context, db *sql.DB, id int64, status string, p *Progress, lastError string, now time.Time) error {
+	issues, _ := json.Marshal(p.Issues)
+	var finished any
+	if status != RunRunning {
+		finished = now
+	}
+	_, err := db.ExecContext(ctx, database.ConvertPlaceholders(`
+		UPDATE helpdesk_import_run SET status = ?, phase = ?, organizations = ?, users = ?, tickets = ?, articles = ?,
+			attachments = ?, skipped = ?, issues = ?, last_error = ?, updated_at = ?, finished_at = ?
+		WHERE id = ?`),
+		status, p.Phase, p.Organizations, p.Users, p.Tickets, p.Articles, p.Attachments, p.Skipped,
+		string(issues), sql.NullString{String: lastError, Valid: lastError != ""}, now, finished, id)
+	if err != nil {
+		return fmt.Errorf("update import run: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,264 @@
+package helpdeskimport
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// Zendesk reads a Zendesk Support account with an API token.
+type Zendesk struct {
+	subdomain string
+	base      string
+	c         *client
+	groups    map[int64]string
+}
+
+// NewZendesk creates a source for https://<subdomain>.zendesk.com; email is
+// the agent the API token belongs to.
+func NewZendesk(subdomain, email, token string) *Zendesk {
+	return newZendesk(subdomain, "https://"+subdomain+".zendesk.com", email, token)
+}
+
+func newZendesk(subdomain, base, email, token string) *Zendesk {
+	return &Zendesk{
+		subdomain: subdomain,
+		base:      base,
+		c: newClient(base, func(r *http.Request) {
+			r.SetBasicAuth(email+"/token", token)
+		}),
+	}
+}
+
+// Name implements Source.
+func (z *Zendesk) Name() string { return "zendesk" }
+
+// Account implements Source.
+func (z *Zendesk) Account() string { return z.subdomain }
+
+// zdPage is the cursor pagination of the Zendesk API.
+type zdPage struct {
+	Meta struct {
+		HasMore bool `json:"has_more"`
+	} `json:"meta"`
+	Links struct {
+		Next string `json:"next"`
+	} `json:"links"`
+}
+
+func (p zdPage) next() string {
+	if !p.Meta.HasMore {
+		return ""
+	}
+	return p.Links.Next
+}
+
+type zdAttachment struct {
+	FileName    string `json:"file_name"`
+	ContentURL  string `json:"content_url"`
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+}
+
+// Organizations implements Source.
+func (z *Zendesk) Organizations(ctx context.Context, fn func(Organization) error) error {
+	next := z.base + "/api/v2/organizations.json?page[size]=100"
+	for next != "" {
+		var page struct {
+			zdPage
+			Organizations []struct {
+				ID   int64  `json:"id"`
+				Name string `json:"name"`
+			} `json:"organizations"`
+		}
+		if _, err := z.c.getJSON(ctx, next, &page); err != nil {
+			return err
+		}
+		for _, o := range page.Organizations {
+			if err := fn(Organization{ID: strconv.FormatInt(o.ID, 10), Name: o.Name}); err != nil {
+				return err
+			}
+		}
+		next = page.next()
+	}
+	return nil
+}
+
+// Users implements Source.
+func (z *Zendesk) Users(ctx context.Context, fn func(User) error) error {
+	next := z.base + "/api/v2/users.json?page[size]=100"
+	for next != "" {
+		var page struct {
+			zdPage
+			Users []struct {
+				ID             int64  `json:"id"`
+				Name           string `json:"name"`
+				Email          string `json:"email"`
+				Phone          string `json:"phone"`
+				OrganizationID *int64 `json:"organization_id"`
+				Role           string `json:"role"`
+			} `json:"users"`
+		}
+		if _, err := z.c.getJSON(ctx, next, &page); err != nil {
+			return err
+		}
+		for _, u := range page.Users {
+			err := fn(User{
+				ID:             strconv.FormatInt(u.ID, 10),
+				Name:           u.Name,
+				Email:          u.Email,
+				Phone:          u.Phone,
+				OrganizationID: idString(u.OrganizationID),
+				Agent:          u.Role == "agent" || u.Role == "admin",
+			})
+			if err != nil {
+				return err
+			}
+		}
+		next = page.next()
+	}
+	return nil
+}
+
+// Tickets implements Source with the incremental export, which lists
+// tickets by update time.
+func (z *Zendesk) Tickets(ctx context.Context, since time.Time, fn func(Ticket) error) error {
+	if err := z.loadGroups(ctx); err != nil {
+		return err
+	}
+	start := max(since.Unix(), 0)
+	next := fmt.Sprintf("%s/api/v2/incremental/tickets/cursor.json?start_time=%d", z.base, start)
+	for next != "" {
+		var page struct {
+			Tickets []struct {
+				ID             int64     `json:"id"`
+				Subject        string    `json:"subject"`
+				Status         string    `json:"status"`
+				Priority       string    `json:"priority"`
+				GroupID        *int64    `json:"group_id"`
+				RequesterID    *int64    `json:"requester_id"`
+				AssigneeID     *int64    `json:"assignee_id"`
+				OrganizationID *int64    `json:"organization_id"`
+				CreatedAt      time.Time `json:"created_at"`
+				UpdatedAt      time.Time `json:"updated_at"`
+			} `json:"tickets"`
+			AfterURL    string `json:"after_url"`
+			EndOfStream bool   `json:"end_of_stream"`
+		}
+		if _, err := z.c.getJSON(ctx, next, &page); err != nil {
+			return err
+		}
+		for _, t := range page.Tickets {
+			if t.Status == "deleted" || !t.UpdatedAt.After(since) {
+				continue
+			}
+			ticket := Ticket{
+				ID:             strconv.FormatInt(t.ID, 10),
+				Subject:        t.Subject,
+				Status:         t.Status,
+				Priority:       t.Priority,
+				RequesterID:    idString(t.RequesterID),
+				AssigneeID:     idString(t.AssigneeID),
+				OrganizationID: idString(t.OrganizationID),
+				CreatedAt:      t.CreatedAt,
+				UpdatedAt:      t.UpdatedAt,
+			}
+			if t.GroupID != nil {
+				ticket.Group = z.groups[*t.GroupID]
+			}
+			if err := fn(ticket); err != nil {
+				return err
+			}
+		}
+		if page.EndOfStream {
+			break
+		}
+		next = page.AfterURL
+	}
+	return nil
+}
+
+func (z *Zendesk) loadGroups(ctx context.Context) error {
+	if z.groups != nil {
+		return nil
+	}
+	groups := map[int64]string{}
+	next := z.base + "/api/v2/groups.json?page[size]=100"
+	for next != "" {
+		var page struct {
+			zdPage
+			Groups []struct {
+				ID   int64  `json:"id"`
+				Name string `json:"name"`
+			} `json:"groups"`
+		}
+		if _, err := z.c.getJSON(ctx, next, &page); err != nil {
+			return err
+		}
+		for _, g := range page.Groups {
+			groups[g.ID] = g.Name
+		}
+		next = page.next()
+	}
+	z.groups = groups
+	return nil
+}
+
+// Comments implements Source.
+func (z *Zendesk) Comments(ctx context.Context, t Ticket) ([]Comment, error) {
+	var comments []Comment
+	next := fmt.Sprintf("%s/api/v2/tickets/%s/comments.json?page[size]=100", z.base, url.PathEscape(t.ID))
+	for next != "" {
+		var page struct {
+			zdPage
+			Comments []struct {
+				ID          int64          `json:"id"`
+				AuthorID    int64          `json:"author_id"`
+				Body        string         `json:"body"`
+				PlainBody   string         `json:"plain_body"`
+				Public      bool           `json:"public"`
+				CreatedAt   time.Time      `json:"created_at"`
+				Attachments []zdAttachment `json:"attachments"`
+			} `json:"comments"`
+		}
+		if _, err := z.c.getJSON(ctx, next, &page); err != nil {
+			return nil, err
+		}
+		for _, c := range page.Comments {
+			body := c.PlainBody
+			if body == "" {
+				body = c.Body
+			}
+			comment := Comment{
+				ID:        strconv.FormatInt(c.ID, 10),
+				AuthorID:  strconv.FormatInt(c.AuthorID, 10),
+				Body:      body,
+				Public:    c.Public,
+				CreatedAt: c.CreatedAt,
+			}
+			for _, a := range c.Attachments {
+				comment.Attachments = append(comment.Attachments, Attachment{
+					FileName: a.FileName, ContentType: a.ContentType, Size: a.Size, URL: a.ContentURL,
+				})
+			}
+			comments = append(comments, comment)
+		}
+		next = page.next()
+	}
+	return comments, nil
+}
+
+// Download implements Source.
+func (z *Zendesk) Download(ctx context.Context, url string, max int64) ([]byte, error) {
+	return z.c.download(ctx, url, max)
+}
+
+func idString(id *int64) string {
+	if id == nil || *id == 0 {
+		return ""
+	}
+	return strconv.FormatInt(*id, 10)
+}
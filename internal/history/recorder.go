@@ -5,6 +5,7 @@ import (
 	"context"
 	"time"
 
+	"github.com/goatkit/goatflow/internal/events"
 	"github.com/goatkit/goatflow/internal/models"
 )
 
@@ -19,6 +20,9 @@ const (
 	TypeSetPendingTime  = "SetPendingTime"
 	TypeMerged          = "Merged"
 	TypeTimeAccounting  = "TimeAccounting"
+	TypeLock            = "Lock"
+	TypeUnlock          = "Unlock"
+	TypeMisc            = "Misc"
 )
 
 // HistoryInserter is an interface for inserting ticket history entries.
@@ -119,7 +123,31 @@ func (r *Recorder) Record(ctx context.Context, tx interface{}, ticket interface{
 		}
 	}
 
-	return inserter.AddTicketHistoryEntry(ctx, tx, entry)
+	if err := inserter.AddTicketHistoryEntry(ctx, tx, entry); err != nil {
+		return err
+	}
+	publishTicketEvent(ticketData, entry)
+	return nil
+}
+
+// publishTicketEvent pushes the recorded change to connected agents.
+func publishTicketEvent(ticket *models.Ticket, entry models.TicketHistoryInsert) {
+	eventType := events.TypeTicketUpdated
+	if entry.HistoryType == TypeNewTicket {
+		eventType = events.TypeTicketCreated
+	}
+	events.Publish(events.Event{
+		Type:     eventType,
+		QueueID:  entry.QueueID,
+		TicketID: entry.TicketID,
+		Data: map[string]any{
+			"tn":           ticket.TicketNumber,
+			"history_type": entry.HistoryType,
+			"state_id":     entry.StateID,
+			"priority_id":  entry.PriorityID,
+			"owner_id":     entry.OwnerID,
+		},
+	})
 }
 
 // RecordByTicketID records a history entry using a ticket ID directly.
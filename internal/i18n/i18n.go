@@ -6,8 +6,10 @@ import (
 	"fmt"
 	"io/fs"
 	"path/filepath"
+	"slices"
 	"strings"
 	"sync"
+	"time"
 )
 
 //go:embed translations/*.json
@@ -19,6 +21,29 @@ type I18n struct {
 	defaultLang    string
 	supportedLangs []string
 	mu             sync.RWMutex
+
+	// Language packs imported at runtime, layered over translations as flat
+	// key -> value maps; see SetLanguagePacks.
+	packs        map[string]map[string]string
+	builtinLangs []string
+
+	missingMu sync.Mutex
+	missing   map[missingKey]*MissingTranslation
+}
+
+// maxMissingTranslations bounds the runtime missing-translation report.
+const maxMissingTranslations = 2000
+
+type missingKey struct{ lang, key string }
+
+// MissingTranslation is a key that was looked up at runtime without a
+// translation in the requested language.
+type MissingTranslation struct {
+	Language string    `json:"language"`
+	Key      string    `json:"key"`
+	Count    int64     `json:"count"`
+	Fallback bool      `json:"fallback"` // the default language had it
+	LastSeen time.Time `json:"last_seen"`
 }
 
 // Config represents i18n configuration.
@@ -102,6 +127,7 @@ func (i *I18n) loadTranslations() error {
 
 		return nil
 	})
+	i.builtinLangs = slices.Clone(i.supportedLangs)
 
 	return err
 }
@@ -116,41 +142,159 @@ func (i *I18n) T(lang, key string, args ...interface{}) string {
 		lang = i.defaultLang
 	}
 
-	// Get translation for language
-	langTranslations, exists := i.translations[lang]
-	if !exists {
-		// Fall back to default language
-		langTranslations = i.translations[i.defaultLang]
-		if langTranslations == nil {
-			return key // Return key if no translations available
-		}
-	}
-
-	// Navigate through nested keys (e.g., "errors.validation.required")
-	value := i.getNestedValue(langTranslations, key)
-	if value == nil {
+	str, ok := i.lookup(lang, key)
+	if !ok {
 		// Try default language if key not found
 		if lang != i.defaultLang {
-			if defaultTranslations, ok := i.translations[i.defaultLang]; ok {
-				value = i.getNestedValue(defaultTranslations, key)
-			}
+			str, ok = i.lookup(i.defaultLang, key)
 		}
+		i.recordMissing(lang, key, ok)
 
 		// Return key if translation not found
-		if value == nil {
+		if !ok {
+			return key
+		}
+	}
+
+	// Format with arguments if provided
+	if len(args) > 0 {
+		return fmt.Sprintf(str, args...)
+	}
+	return str
+}
+
+// Plural translates a key that has one entry per CLDR plural category
+// (e.g. "tickets.count.one", "tickets.count.other"), picking the form for
+// count in the language. The count is the first format argument, followed
+// by args. Missing categories fall back to "other".
+func (i *I18n) Plural(lang, key string, count int, args ...interface{}) string {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	if !i.isSupported(lang) {
+		lang = i.defaultLang
+	}
+
+	str, ok := i.lookupPlural(lang, key, count)
+	if !ok {
+		if lang != i.defaultLang {
+			str, ok = i.lookupPlural(i.defaultLang, key, count)
+		}
+		i.recordMissing(lang, key, ok)
+		if !ok {
 			return key
 		}
 	}
 
-	// Convert to string and format with arguments if provided
-	if str, ok := value.(string); ok {
-		if len(args) > 0 {
-			return fmt.Sprintf(str, args...)
+	if strings.Contains(str, "%") {
+		return fmt.Sprintf(str, append([]interface{}{count}, args...)...)
+	}
+	return str
+}
+
+// lookupPlural finds the plural form of key for count in one language.
+func (i *I18n) lookupPlural(lang, key string, count int) (string, bool) {
+	if str, ok := i.lookup(lang, key+"."+PluralCategory(lang, count)); ok {
+		return str, true
+	}
+	return i.lookup(lang, key+"."+PluralOther)
+}
+
+// lookup finds a key in one language, in its language pack first.
+func (i *I18n) lookup(lang, key string) (string, bool) {
+	if str, ok := i.packs[lang][key]; ok {
+		return str, true
+	}
+	str, ok := i.getNestedValue(i.translations[lang], key).(string)
+	return str, ok
+}
+
+// recordMissing notes a key without a translation in lang for the missing
+// translation report.
+func (i *I18n) recordMissing(lang, key string, fallback bool) {
+	i.missingMu.Lock()
+	defer i.missingMu.Unlock()
+
+	k := missingKey{lang, key}
+	m, ok := i.missing[k]
+	if !ok {
+		if len(i.missing) >= maxMissingTranslations {
+			return
+		}
+		if i.missing == nil {
+			i.missing = make(map[missingKey]*MissingTranslation)
+		}
+		m = &MissingTranslation{Language: lang, Key: key, Fallback: fallback}
+		i.missing[k] = m
+	}
+	m.Count++
+	m.LastSeen = time.Now()
+}
+
+// MissingTranslations returns the keys looked up without a translation since
+// start or the last reset, most requested first. An empty lang returns all
+// languages.
+func (i *I18n) MissingTranslations(lang string) []MissingTranslation {
+	i.missingMu.Lock()
+	defer i.missingMu.Unlock()
+
+	list := make([]MissingTranslation, 0, len(i.missing))
+	for _, m := range i.missing {
+		if lang == "" || m.Language == lang {
+			list = append(list, *m)
+		}
+	}
+	slices.SortFunc(list, func(a, b MissingTranslation) int {
+		if a.Count != b.Count {
+			return int(b.Count - a.Count)
+		}
+		if a.Language != b.Language {
+			return strings.Compare(a.Language, b.Language)
+		}
+		return strings.Compare(a.Key, b.Key)
+	})
+	return list
+}
+
+// ResetMissingTranslations clears the missing translation report.
+func (i *I18n) ResetMissingTranslations() {
+	i.missingMu.Lock()
+	i.missing = nil
+	i.missingMu.Unlock()
+}
+
+// SetLanguagePacks replaces the language packs layered over the built-in
+// translations. Each pack maps flat dot-notation keys to translations; its
+// entries take precedence, and packs for new languages make them supported.
+func (i *I18n) SetLanguagePacks(packs map[string]map[string]string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if i.builtinLangs == nil {
+		i.builtinLangs = slices.Clone(i.supportedLangs)
+	}
+	langs := slices.Clone(i.builtinLangs)
+	added := make([]string, 0, len(packs))
+	for lang := range packs {
+		if !slices.Contains(langs, lang) {
+			added = append(added, lang)
 		}
-		return str
 	}
+	slices.Sort(added)
+	i.packs = packs
+	i.supportedLangs = append(langs, added...)
+}
 
-	return key
+// LanguagePack returns a copy of the language pack of a language.
+func (i *I18n) LanguagePack(lang string) map[string]string {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+
+	pack := make(map[string]string, len(i.packs[lang]))
+	for k, v := range i.packs[lang] {
+		pack[k] = v
+	}
+	return pack
 }
 
 // Translate is an alias for T.
@@ -189,7 +333,9 @@ func (i *I18n) isSupported(lang string) bool {
 
 // GetSupportedLanguages returns the list of supported languages.
 func (i *I18n) GetSupportedLanguages() []string {
-	return i.supportedLangs
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	return slices.Clone(i.supportedLangs)
 }
 
 // GetDefaultLanguage returns the default language.
@@ -199,12 +345,12 @@ func (i *I18n) GetDefaultLanguage() string {
 
 // SetDefaultLanguage sets the default language.
 func (i *I18n) SetDefaultLanguage(lang string) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
 	if !i.isSupported(lang) {
 		return fmt.Errorf("language %s is not supported", lang)
 	}
-	i.mu.Lock()
 	i.defaultLang = lang
-	i.mu.Unlock()
 	return nil
 }
 
@@ -276,12 +422,19 @@ func (i *I18n) mergeTranslations(target, source map[string]interface{}) {
 	}
 }
 
-// GetTranslations returns all translations for a language.
+// GetTranslations returns all translations for a language, with its
+// language pack merged in.
 func (i *I18n) GetTranslations(lang string) map[string]interface{} {
 	i.mu.RLock()
 	defer i.mu.RUnlock()
 
-	return i.translations[lang]
+	pack := i.packs[lang]
+	if len(pack) == 0 {
+		return i.translations[lang]
+	}
+	merged := UnflattenTranslations(FlattenTranslations(i.translations[lang]))
+	i.mergeTranslations(merged, UnflattenTranslations(pack))
+	return merged
 }
 
 // GetAllKeys returns all translation keys for a language in dot notation.
@@ -289,13 +442,13 @@ func (i *I18n) GetAllKeys(lang string) []string {
 	i.mu.RLock()
 	defer i.mu.RUnlock()
 
-	translations := i.translations[lang]
-	if translations == nil {
-		return []string{}
-	}
-
 	keys := []string{}
-	i.extractKeys(translations, "", &keys)
+	i.extractKeys(i.translations[lang], "", &keys)
+	for key := range i.packs[lang] {
+		if _, ok := i.getNestedValue(i.translations[lang], key).(string); !ok {
+			keys = append(keys, key)
+		}
+	}
 	return keys
 }
 
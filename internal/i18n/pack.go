@@ -0,0 +1,181 @@
+package i18n
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FlattenTranslations converts nested translations to dot-notation keys.
+// Non-string leaves are skipped.
+func FlattenTranslations(m map[string]interface{}) map[string]string {
+	flat := make(map[string]string)
+	flattenInto(flat, m, "")
+	return flat
+}
+
+func flattenInto(flat map[string]string, m map[string]interface{}, prefix string) {
+	for key, value := range m {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		switch v := value.(type) {
+		case map[string]interface{}:
+			flattenInto(flat, v, key)
+		case string:
+			flat[key] = v
+		}
+	}
+}
+
+// UnflattenTranslations converts dot-notation keys back to nested maps.
+// When a key is both a value and a prefix of other keys, the nested keys win.
+func UnflattenTranslations(flat map[string]string) map[string]interface{} {
+	keys := make([]string, 0, len(flat))
+	for key := range flat {
+		keys = append(keys, key)
+	}
+	// Shorter keys first so nested keys replace conflicting values.
+	slices.Sort(keys)
+
+	nested := make(map[string]interface{})
+	for _, key := range keys {
+		parts := strings.Split(key, ".")
+		current := nested
+		for _, part := range parts[:len(parts)-1] {
+			next, ok := current[part].(map[string]interface{})
+			if !ok {
+				next = make(map[string]interface{})
+				current[part] = next
+			}
+			current = next
+		}
+		if _, isMap := current[parts[len(parts)-1]].(map[string]interface{}); !isMap {
+			current[parts[len(parts)-1]] = flat[key]
+		}
+	}
+	return nested
+}
+
+// ParseJSONPack parses a language pack in JSON, either nested like the
+// built-in translation files or flat with dot-notation keys.
+func ParseJSONPack(data []byte) (map[string]string, error) {
+	var m map[string]interface{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+	return FlattenTranslations(m), nil
+}
+
+// ParsePO parses a gettext PO file as exported by WritePO. Entries are keyed
+// by msgctxt, or by msgid when there is no context. The header, fuzzy and
+// untranslated entries are skipped.
+func ParsePO(r io.Reader) (map[string]string, error) {
+	entries := make(map[string]string)
+	var (
+		ctxt, id, str  string
+		field          *string
+		hasCtxt, fuzzy bool
+		done           bool // msgstr seen; the next keyword starts a new entry
+	)
+	flush := func() {
+		key := id
+		if hasCtxt {
+			key = ctxt
+		}
+		if done && key != "" && str != "" && !fuzzy {
+			entries[key] = str
+		}
+		ctxt, id, str, field = "", "", "", nil
+		hasCtxt, fuzzy, done = false, false, false
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if done && line != "" && !strings.HasPrefix(line, `"`) {
+			flush()
+		}
+		switch {
+		case line == "":
+			flush()
+		case strings.HasPrefix(line, "#,"):
+			fuzzy = fuzzy || strings.Contains(line, "fuzzy")
+		case strings.HasPrefix(line, "#"):
+		case strings.HasPrefix(line, `"`):
+			if field == nil {
+				return nil, fmt.Errorf("line %d: string without keyword", n)
+			}
+			s, err := strconv.Unquote(line)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", n, err)
+			}
+			*field += s
+		default:
+			keyword, rest, _ := strings.Cut(line, " ")
+			s, err := strconv.Unquote(strings.TrimSpace(rest))
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", n, err)
+			}
+			switch keyword {
+			case "msgctxt":
+				ctxt, hasCtxt, field = s, true, &ctxt
+			case "msgid":
+				id, field = s, &id
+			case "msgstr":
+				str, field, done = s, &str, true
+			case "msgid_plural":
+				return nil, fmt.Errorf("line %d: plural entries are not supported; use one key per plural category", n)
+			default:
+				return nil, fmt.Errorf("line %d: unknown keyword %q", n, keyword)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	flush()
+	return entries, nil
+}
+
+// WritePO writes translations as a gettext PO file. Each entry uses the key
+// as msgctxt and the source (English) text as msgid, so translators see the
+// original next to the translation; entries without a source use the key.
+func WritePO(w io.Writer, lang string, entries, source map[string]string) error {
+	var buf bytes.Buffer
+	buf.WriteString("msgid \"\"\nmsgstr \"\"\n")
+	fmt.Fprintf(&buf, "%s\n", poQuote("Project-Id-Version: GoatFlow\n"))
+	fmt.Fprintf(&buf, "%s\n", poQuote("PO-Revision-Date: "+time.Now().UTC().Format("2006-01-02 15:04-0700")+"\n"))
+	fmt.Fprintf(&buf, "%s\n", poQuote("Language: "+lang+"\n"))
+	fmt.Fprintf(&buf, "%s\n", poQuote("MIME-Version: 1.0\n"))
+	fmt.Fprintf(&buf, "%s\n", poQuote("Content-Type: text/plain; charset=UTF-8\n"))
+	fmt.Fprintf(&buf, "%s\n", poQuote("Content-Transfer-Encoding: 8bit\n"))
+
+	keys := make([]string, 0, len(entries))
+	for key := range entries {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		msgid := source[key]
+		if msgid == "" {
+			msgid = key
+		}
+		fmt.Fprintf(&buf, "\nmsgctxt %s\nmsgid %s\nmsgstr %s\n", poQuote(key), poQuote(msgid), poQuote(entries[key]))
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// poQuote quotes a string for a PO file.
+func poQuote(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`, "\r", `\r`)
+	return `"` + r.Replace(s) + `"`
+}
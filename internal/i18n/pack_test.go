@@ -0,0 +1,227 @@
+package i18n
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func newTestI18n() *I18n {
+	return &I18n{
+		translations: map[string]map[string]interface{}{
+			"en": {
+				"app":     map[string]interface{}{"name": "GoatFlow", "title": "Help desk"},
+				"tickets": map[string]interface{}{"count": map[string]interface{}{"one": "%d ticket", "other": "%d tickets"}},
+			},
+			"de": {
+				"app": map[string]interface{}{"title": "Helpdesk"},
+			},
+		},
+		defaultLang:    "en",
+		supportedLangs: []string{"en", "de"},
+	}
+}
+
+func TestFlattenUnflattenTranslations(t *testing.T) {
+	nested := map[string]interface{}{
+		"a": map[string]interface{}{"b": "1", "c": map[string]interface{}{"d": "2"}},
+		"e": "3",
+		"n": 4.0,
+	}
+	flat := FlattenTranslations(nested)
+	want := map[string]string{"a.b": "1", "a.c.d": "2", "e": "3"}
+	if !reflect.DeepEqual(flat, want) {
+		t.Fatalf("FlattenTranslations = %v, want %v", flat, want)
+	}
+	delete(nested, "n")
+	if got := UnflattenTranslations(flat); !reflect.DeepEqual(got, nested) {
+		t.Errorf("UnflattenTranslations = %v, want %v", got, nested)
+	}
+	got := UnflattenTranslations(map[string]string{"x": "value", "x.y": "nested"})
+	if !reflect.DeepEqual(got, map[string]interface{}{"x": map[string]interface{}{"y": "nested"}}) {
+		t.Errorf("nested keys should win over a conflicting value, got %v", got)
+	}
+}
+
+func TestParseJSONPack(t *testing.T) {
+	pack, err := ParseJSONPack([]byte(`{"app": {"title": "Ayuda"}, "tickets.count.one": "%d tique"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"app.title": "Ayuda", "tickets.count.one": "%d tique"}
+	if !reflect.DeepEqual(pack, want) {
+		t.Errorf("ParseJSONPack = %v, want %v", pack, want)
+	}
+	if _, err := ParseJSONPack([]byte(`["not", "an", "object"]`)); err == nil {
+		t.Error("expected an error for a JSON array")
+	}
+}
+
+func TestPORoundTrip(t *testing.T) {
+	entries := map[string]string{
+		"app.title":     `Centre "d'aide"`,
+		"app.multiline": "Line one\nLine two\tend \\ done",
+		"app.unicode":   "Ticket übernehmen",
+	}
+	source := map[string]string{"app.title": "Help desk"}
+
+	var buf bytes.Buffer
+	if err := WritePO(&buf, "fr", entries, source); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	if !strings.Contains(out, `"Language: fr\n"`) || !strings.Contains(out, `msgid "Help desk"`) {
+		t.Errorf("unexpected PO output:\n%s", out)
+	}
+
+	parsed, err := ParsePO(strings.NewReader(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(parsed, entries) {
+		t.Errorf("ParsePO(WritePO()) = %v, want %v", parsed, entries)
+	}
+}
+
+func TestParsePO(t *testing.T) {
+	po := `# Translator comment
+msgid ""
+msgstr ""
+"Language: de\n"
+
+msgctxt "app.title"
+msgid "Help desk"
+msgstr ""
+"Help"
+"desk"
+
+#, fuzzy
+msgctxt "app.name"
+msgid "GoatFlow"
+msgstr "Ziege"
+
+msgid "tickets.title"
+msgstr "Tickets"
+msgid "tickets.empty"
+msgstr ""
+`
+	parsed, err := ParsePO(strings.NewReader(po))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"app.title": "Helpdesk", "tickets.title": "Tickets"}
+	if !reflect.DeepEqual(parsed, want) {
+		t.Errorf("ParsePO = %v, want %v", parsed, want)
+	}
+
+	for _, bad := range []string{
+		"msgid \"a\"\nmsgid_plural \"as\"\nmsgstr[0] \"x\"\n",
+		"msgid unquoted\n",
+		"\"orphan\"\n",
+	} {
+		if _, err := ParsePO(strings.NewReader(bad)); err == nil {
+			t.Errorf("expected an error for %q", bad)
+		}
+	}
+}
+
+func TestLanguagePacks(t *testing.T) {
+	i := newTestI18n()
+
+	if got := i.T("de", "app.title"); got != "Helpdesk" {
+		t.Fatalf("built-in translation = %q", got)
+	}
+	i.SetLanguagePacks(map[string]map[string]string{
+		"de": {"app.title": "Kundendienst", "app.name": "Ziege"},
+		"sv": {"app.title": "Kundtjänst"},
+	})
+	if got := i.T("de", "app.title"); got != "Kundendienst" {
+		t.Errorf("pack should override the built-in translation, got %q", got)
+	}
+	if got := i.T("sv", "app.title"); got != "Kundtjänst" {
+		t.Errorf("pack languages should become supported, got %q", got)
+	}
+	if got := i.T("sv", "app.name"); got != "GoatFlow" {
+		t.Errorf("pack languages fall back to the default language, got %q", got)
+	}
+	if langs := i.GetSupportedLanguages(); !reflect.DeepEqual(langs, []string{"en", "de", "sv"}) {
+		t.Errorf("supported languages = %v", langs)
+	}
+	if got := i.GetTranslations("de")["app"].(map[string]interface{})["name"]; got != "Ziege" {
+		t.Errorf("GetTranslations should merge the pack, got %v", got)
+	}
+	if pack := i.LanguagePack("sv"); len(pack) != 1 {
+		t.Errorf("LanguagePack(sv) = %v", pack)
+	}
+
+	// Reloading replaces the packs without a restart.
+	i.SetLanguagePacks(map[string]map[string]string{"de": {"app.name": "Geiß"}})
+	if got := i.T("de", "app.title"); got != "Helpdesk" {
+		t.Errorf("removed pack entries should fall back to built-ins, got %q", got)
+	}
+	if got := i.T("sv", "app.title"); got != "Help desk" {
+		t.Errorf("removed pack languages should use the default language, got %q", got)
+	}
+	if langs := i.GetSupportedLanguages(); !reflect.DeepEqual(langs, []string{"en", "de"}) {
+		t.Errorf("supported languages = %v", langs)
+	}
+}
+
+func TestPlural(t *testing.T) {
+	i := newTestI18n()
+	i.SetLanguagePacks(map[string]map[string]string{
+		"ru": {"tickets.count.one": "%d тикет", "tickets.count.few": "%d тикета", "tickets.count.many": "%d тикетов"},
+		"de": {"tickets.count.other": "%d Tickets"},
+	})
+
+	tests := []struct {
+		lang  string
+		count int
+		want  string
+	}{
+		{"en", 1, "1 ticket"},
+		{"en", 0, "0 tickets"},
+		{"ru", 21, "21 тикет"},
+		{"ru", 3, "3 тикета"},
+		{"ru", 11, "11 тикетов"},
+		{"de", 1, "1 Tickets"}, // only "other" is translated
+		{"xx", 2, "2 tickets"},
+	}
+	for _, tt := range tests {
+		if got := i.Plural(tt.lang, "tickets.count", tt.count); got != tt.want {
+			t.Errorf("Plural(%q, %d) = %q, want %q", tt.lang, tt.count, got, tt.want)
+		}
+	}
+	if got := i.Plural("en", "missing.key", 2); got != "missing.key" {
+		t.Errorf("missing plural keys should return the key, got %q", got)
+	}
+}
+
+func TestMissingTranslations(t *testing.T) {
+	i := newTestI18n()
+
+	i.T("de", "app.name")
+	i.T("de", "app.name")
+	i.T("de", "nope")
+	i.T("en", "app.title")
+
+	missing := i.MissingTranslations("")
+	if len(missing) != 2 {
+		t.Fatalf("missing = %+v", missing)
+	}
+	if m := missing[0]; m.Language != "de" || m.Key != "app.name" || m.Count != 2 || !m.Fallback {
+		t.Errorf("first missing entry = %+v", m)
+	}
+	if m := missing[1]; m.Key != "nope" || m.Fallback {
+		t.Errorf("second missing entry = %+v", m)
+	}
+	if got := i.MissingTranslations("en"); len(got) != 0 {
+		t.Errorf("missing for en = %+v", got)
+	}
+
+	i.ResetMissingTranslations()
+	if got := i.MissingTranslations(""); len(got) != 0 {
+		t.Errorf("missing after reset = %+v", got)
+	}
+}
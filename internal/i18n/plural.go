@@ -0,0 +1,146 @@
+package i18n
+
+import "strings"
+
+// CLDR plural categories.
+const (
+	PluralZero  = "zero"
+	PluralOne   = "one"
+	PluralTwo   = "two"
+	PluralFew   = "few"
+	PluralMany  = "many"
+	PluralOther = "other"
+)
+
+// pluralRule picks the plural category of a non-negative integer.
+type pluralRule struct {
+	categories []string
+	pick       func(n int) string
+}
+
+var (
+	pluralOther = pluralRule{[]string{PluralOther}, func(int) string { return PluralOther }}
+
+	pluralOneOther = pluralRule{[]string{PluralOne, PluralOther}, func(n int) string {
+		if n == 1 {
+			return PluralOne
+		}
+		return PluralOther
+	}}
+
+	// Romance languages use "many" for exact millions ("1000000 de ...").
+	pluralOneManyOther = pluralRule{[]string{PluralOne, PluralMany, PluralOther}, func(n int) string {
+		switch {
+		case n == 1:
+			return PluralOne
+		case n != 0 && n%1000000 == 0:
+			return PluralMany
+		}
+		return PluralOther
+	}}
+
+	// French and Portuguese treat 0 like 1.
+	pluralZeroOneManyOther = pluralRule{[]string{PluralOne, PluralMany, PluralOther}, func(n int) string {
+		switch {
+		case n == 0 || n == 1:
+			return PluralOne
+		case n%1000000 == 0:
+			return PluralMany
+		}
+		return PluralOther
+	}}
+
+	pluralZeroOneOther = pluralRule{[]string{PluralOne, PluralOther}, func(n int) string {
+		if n == 0 || n == 1 {
+			return PluralOne
+		}
+		return PluralOther
+	}}
+
+	pluralEastSlavic = pluralRule{[]string{PluralOne, PluralFew, PluralMany, PluralOther}, func(n int) string {
+		switch mod10, mod100 := n%10, n%100; {
+		case mod10 == 1 && mod100 != 11:
+			return PluralOne
+		case mod10 >= 2 && mod10 <= 4 && (mod100 < 12 || mod100 > 14):
+			return PluralFew
+		}
+		return PluralMany
+	}}
+
+	pluralPolish = pluralRule{[]string{PluralOne, PluralFew, PluralMany, PluralOther}, func(n int) string {
+		switch mod10, mod100 := n%10, n%100; {
+		case n == 1:
+			return PluralOne
+		case mod10 >= 2 && mod10 <= 4 && (mod100 < 12 || mod100 > 14):
+			return PluralFew
+		}
+		return PluralMany
+	}}
+
+	pluralArabic = pluralRule{[]string{PluralZero, PluralOne, PluralTwo, PluralFew, PluralMany, PluralOther}, func(n int) string {
+		switch mod100 := n % 100; {
+		case n == 0:
+			return PluralZero
+		case n == 1:
+			return PluralOne
+		case n == 2:
+			return PluralTwo
+		case mod100 >= 3 && mod100 <= 10:
+			return PluralFew
+		case mod100 >= 11:
+			return PluralMany
+		}
+		return PluralOther
+	}}
+
+	pluralHebrew = pluralRule{[]string{PluralOne, PluralTwo, PluralOther}, func(n int) string {
+		switch n {
+		case 1:
+			return PluralOne
+		case 2:
+			return PluralTwo
+		}
+		return PluralOther
+	}}
+)
+
+// pluralRules maps language codes to their CLDR plural rule for integers.
+// Languages not listed use one/other.
+var pluralRules = map[string]pluralRule{
+	"ja": pluralOther, "zh": pluralOther, "ko": pluralOther, "vi": pluralOther,
+	"th": pluralOther, "id": pluralOther, "ms": pluralOther,
+	"es": pluralOneManyOther, "it": pluralOneManyOther, "ca": pluralOneManyOther,
+	"fr": pluralZeroOneManyOther, "pt": pluralZeroOneManyOther,
+	"fa": pluralZeroOneOther, "hi": pluralZeroOneOther, "bn": pluralZeroOneOther,
+	"ru": pluralEastSlavic, "uk": pluralEastSlavic, "be": pluralEastSlavic,
+	"pl": pluralPolish,
+	"ar": pluralArabic,
+	"he": pluralHebrew,
+}
+
+// pluralRuleFor returns the rule of a language; regional variants such as
+// "pt-BR" use the rule of their base language.
+func pluralRuleFor(lang string) pluralRule {
+	lang = strings.ToLower(lang)
+	if idx := strings.IndexAny(lang, "-_"); idx > 0 {
+		lang = lang[:idx]
+	}
+	if rule, ok := pluralRules[lang]; ok {
+		return rule
+	}
+	return pluralOneOther
+}
+
+// PluralCategory returns the CLDR plural category of count in a language.
+func PluralCategory(lang string, count int) string {
+	if count < 0 {
+		count = -count
+	}
+	return pluralRuleFor(lang).pick(count)
+}
+
+// PluralCategories returns the plural categories a language distinguishes,
+// in CLDR order.
+func PluralCategories(lang string) []string {
+	return append([]string(nil), pluralRuleFor(lang).categories...)
+}
@@ -0,0 +1,76 @@
+package i18n
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPluralCategory(t *testing.T) {
+	tests := []struct {
+		lang  string
+		count int
+		want  string
+	}{
+		{"en", 0, PluralOther},
+		{"en", 1, PluralOne},
+		{"en", 2, PluralOther},
+		{"en", -1, PluralOne},
+		{"de", 1, PluralOne},
+		{"ja", 1, PluralOther},
+		{"zh", 5, PluralOther},
+		{"fr", 0, PluralOne},
+		{"fr", 1, PluralOne},
+		{"fr", 2, PluralOther},
+		{"fr", 1000000, PluralMany},
+		{"pt-BR", 0, PluralOne},
+		{"es", 0, PluralOther},
+		{"es", 1, PluralOne},
+		{"es", 2000000, PluralMany},
+		{"ru", 1, PluralOne},
+		{"ru", 21, PluralOne},
+		{"ru", 11, PluralMany},
+		{"ru", 3, PluralFew},
+		{"ru", 22, PluralFew},
+		{"ru", 12, PluralMany},
+		{"ru", 5, PluralMany},
+		{"uk", 101, PluralOne},
+		{"pl", 1, PluralOne},
+		{"pl", 21, PluralMany},
+		{"pl", 22, PluralFew},
+		{"pl", 14, PluralMany},
+		{"ar", 0, PluralZero},
+		{"ar", 1, PluralOne},
+		{"ar", 2, PluralTwo},
+		{"ar", 3, PluralFew},
+		{"ar", 110, PluralFew},
+		{"ar", 11, PluralMany},
+		{"ar", 99, PluralMany},
+		{"ar", 100, PluralOther},
+		{"ar", 102, PluralOther},
+		{"he", 2, PluralTwo},
+		{"he", 3, PluralOther},
+		{"tlh", 1, PluralOne},
+	}
+	for _, tt := range tests {
+		if got := PluralCategory(tt.lang, tt.count); got != tt.want {
+			t.Errorf("PluralCategory(%q, %d) = %q, want %q", tt.lang, tt.count, got, tt.want)
+		}
+	}
+}
+
+func TestPluralCategories(t *testing.T) {
+	if got := PluralCategories("en"); !reflect.DeepEqual(got, []string{PluralOne, PluralOther}) {
+		t.Errorf("en categories = %v", got)
+	}
+	if got := PluralCategories("ja"); !reflect.DeepEqual(got, []string{PluralOther}) {
+		t.Errorf("ja categories = %v", got)
+	}
+	if got := PluralCategories("ar"); len(got) != 6 {
+		t.Errorf("ar categories = %v", got)
+	}
+	// Callers may modify the result.
+	PluralCategories("en")[0] = "x"
+	if PluralCategories("en")[0] != PluralOne {
+		t.Error("PluralCategories must return a copy")
+	}
+}
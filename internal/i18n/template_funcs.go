@@ -12,6 +12,7 @@ func TemplateFuncs(lang string) template.FuncMap {
 		"t":              createTranslateFunc(lang),
 		"T":              createTranslateFunc(lang),
 		"trans":          createTranslateFunc(lang),
+		"tn":             createPluralTranslateFunc(lang),
 		"timeAgo":        createTimeAgoFunc(lang),
 		"formatDate":     createFormatDateFunc(lang),
 		"formatTime":     createFormatTimeFunc(lang),
@@ -30,6 +31,14 @@ func createTranslateFunc(lang string) func(key string, args ...interface{}) stri
 	}
 }
 
+// createPluralTranslateFunc creates a plural-aware translate function for
+// templates.
+func createPluralTranslateFunc(lang string) func(key string, count int, args ...interface{}) string {
+	return func(key string, count int, args ...interface{}) string {
+		return GetInstance().Plural(lang, key, count, args...)
+	}
+}
+
 // createTimeAgoFunc creates a time ago function for templates.
 func createTimeAgoFunc(lang string) func(t time.Time) string {
 	return func(t time.Time) string {
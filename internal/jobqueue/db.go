@@ -0,0 +1,258 @@
+package jobqueue
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/goatkit/goatflow/internal/database"
+)
+
+const dbJobSelect = `
+	SELECT id, queue, job_type, payload, status, attempts, max_attempts, run_at,
+	       locked_until, lock_token, last_error, create_time, change_time
+	FROM job_queue`
+
+// dueCondition matches jobs a worker may take: pending ones whose time has
+// come and running ones whose lease ran out.
+const dueCondition = `((status = 'pending' AND run_at <= ?) OR (status = 'running' AND locked_until <= ?))`
+
+// DBDriver stores jobs in the job_queue table. Workers claim a job with a
+// conditional UPDATE, so any number of processes can share the table.
+type DBDriver struct {
+	db *sql.DB
+}
+
+// NewDBDriver creates a driver using db.
+func NewDBDriver(db *sql.DB) *DBDriver {
+	return &DBDriver{db: db}
+}
+
+func (d *DBDriver) exec(ctx context.Context, query string, args ...interface{}) (int64, error) {
+	res, err := d.db.ExecContext(ctx, database.ConvertPlaceholders(query), args...)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
+
+// Enqueue implements Driver.
+func (d *DBDriver) Enqueue(ctx context.Context, job *Job) error {
+	_, err := d.exec(ctx, `
+		INSERT INTO job_queue (id, queue, job_type, payload, status, attempts, max_attempts, run_at,
+			create_time, change_time)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		job.ID, job.Queue, job.Type, string(job.Payload), string(job.Status), job.Attempts, job.MaxAttempts,
+		job.RunAt, job.CreatedAt, job.UpdatedAt)
+	return err
+}
+
+// Reserve implements Driver. It reads a few due candidates and claims the
+// first one no other worker has claimed in the meantime.
+func (d *DBDriver) Reserve(ctx context.Context, queue string, lease time.Duration) (*Job, error) {
+	for round := 0; round < 3; round++ {
+		now := time.Now()
+		rows, err := d.db.QueryContext(ctx, database.ConvertPlaceholders(`
+			SELECT id FROM job_queue
+			WHERE queue = ? AND `+dueCondition+`
+			ORDER BY run_at, create_time
+			LIMIT 5`), queue, now, now)
+		if err != nil {
+			return nil, fmt.Errorf("find due jobs: %w", err)
+		}
+		var ids []string
+		for rows.Next() {
+			var id string
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("scan job id: %w", err)
+			}
+			ids = append(ids, id)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("find due jobs: %w", err)
+		}
+		if len(ids) == 0 {
+			return nil, nil
+		}
+
+		for _, id := range ids {
+			token, err := newJobID()
+			if err != nil {
+				return nil, err
+			}
+			n, err := d.exec(ctx, `
+				UPDATE job_queue
+				SET status = 'running', attempts = attempts + 1, lock_token = ?, locked_until = ?, change_time = ?
+				WHERE id = ? AND `+dueCondition,
+				token, now.Add(lease), now, id, now, now)
+			if err != nil {
+				return nil, fmt.Errorf("claim job: %w", err)
+			}
+			if n == 1 {
+				return d.get(ctx, id)
+			}
+		}
+		// Every candidate went to another worker; look again.
+	}
+	return nil, nil
+}
+
+func (d *DBDriver) get(ctx context.Context, id string) (*Job, error) {
+	row := d.db.QueryRowContext(ctx, database.ConvertPlaceholders(dbJobSelect+" WHERE id = ?"), id)
+	job, err := scanDBJob(row)
+	if err == sql.ErrNoRows {
+		return nil, ErrJobNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("load job: %w", err)
+	}
+	return job, nil
+}
+
+// Complete implements Driver.
+func (d *DBDriver) Complete(ctx context.Context, job *Job) error {
+	n, err := d.exec(ctx, `DELETE FROM job_queue WHERE id = ? AND lock_token = ?`, job.ID, job.Token)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrLeaseLost
+	}
+	return nil
+}
+
+// Retry implements Driver.
+func (d *DBDriver) Retry(ctx context.Context, job *Job, runAt time.Time, lastError string) error {
+	return d.release(ctx, job, StatusPending, runAt, lastError)
+}
+
+// Fail implements Driver.
+func (d *DBDriver) Fail(ctx context.Context, job *Job, lastError string) error {
+	return d.release(ctx, job, StatusFailed, job.RunAt, lastError)
+}
+
+func (d *DBDriver) release(ctx context.Context, job *Job, status Status, runAt time.Time, lastError string) error {
+	n, err := d.exec(ctx, `
+		UPDATE job_queue
+		SET status = ?, run_at = ?, last_error = ?, lock_token = NULL, locked_until = NULL, change_time = ?
+		WHERE id = ? AND lock_token = ?`,
+		string(status), runAt, lastError, time.Now(), job.ID, job.Token)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrLeaseLost
+	}
+	return nil
+}
+
+// Stats implements Driver.
+func (d *DBDriver) Stats(ctx context.Context) ([]QueueStats, error) {
+	now := time.Now()
+	rows, err := d.db.QueryContext(ctx, database.ConvertPlaceholders(`
+		SELECT queue,
+		       SUM(CASE WHEN status = 'pending' AND run_at <= ? THEN 1 ELSE 0 END),
+		       SUM(CASE WHEN status = 'pending' AND run_at > ? THEN 1 ELSE 0 END),
+		       SUM(CASE WHEN status = 'running' THEN 1 ELSE 0 END),
+		       SUM(CASE WHEN status = 'failed' THEN 1 ELSE 0 END)
+		FROM job_queue
+		GROUP BY queue
+		ORDER BY queue`), now, now)
+	if err != nil {
+		return nil, fmt.Errorf("count jobs: %w", err)
+	}
+	defer rows.Close()
+
+	var stats []QueueStats
+	for rows.Next() {
+		var s QueueStats
+		if err := rows.Scan(&s.Queue, &s.Pending, &s.Scheduled, &s.Running, &s.Failed); err != nil {
+			return nil, fmt.Errorf("scan job counts: %w", err)
+		}
+		stats = append(stats, s)
+	}
+	return stats, rows.Err()
+}
+
+// Failed implements Driver.
+func (d *DBDriver) Failed(ctx context.Context, queue string, limit int) ([]*Job, error) {
+	query := dbJobSelect + " WHERE status = 'failed'"
+	args := []interface{}{}
+	if queue != "" {
+		query += " AND queue = ?"
+		args = append(args, queue)
+	}
+	query += " ORDER BY change_time DESC, id LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := d.db.QueryContext(ctx, database.ConvertPlaceholders(query), args...)
+	if err != nil {
+		return nil, fmt.Errorf("list failed jobs: %w", err)
+	}
+	defer rows.Close()
+
+	var jobs []*Job
+	for rows.Next() {
+		job, err := scanDBJob(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan job: %w", err)
+		}
+		jobs = append(jobs, job)
+	}
+	return jobs, rows.Err()
+}
+
+// Requeue implements Driver.
+func (d *DBDriver) Requeue(ctx context.Context, id string) error {
+	now := time.Now()
+	n, err := d.exec(ctx, `
+		UPDATE job_queue SET status = 'pending', attempts = 0, run_at = ?, change_time = ?
+		WHERE id = ? AND status = 'failed'`, now, now, id)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrJobNotFound
+	}
+	return nil
+}
+
+// Delete implements Driver.
+func (d *DBDriver) Delete(ctx context.Context, id string) error {
+	n, err := d.exec(ctx, `DELETE FROM job_queue WHERE id = ? AND status IN ('pending', 'failed')`, id)
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrJobNotFound
+	}
+	return nil
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanDBJob(row rowScanner) (*Job, error) {
+	var job Job
+	var payload, status string
+	var lockedUntil sql.NullTime
+	var token, lastError sql.NullString
+	err := row.Scan(&job.ID, &job.Queue, &job.Type, &payload, &status, &job.Attempts, &job.MaxAttempts,
+		&job.RunAt, &lockedUntil, &token, &lastError, &job.CreatedAt, &job.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	job.Payload = []byte(payload)
+	job.Status = Status(status)
+	if lockedUntil.Valid {
+		t := lockedUntil.Time
+		job.LockedUntil = &t
+	}
+	job.Token = token.String
+	job.LastError = lastError.String
+	return &job, nil
+}
@@ -0,0 +1,186 @@
+// Package jobqueue runs work such as sending mail, delivering webhooks,
+// generating reports and importing data in the background, outside the
+// request that asked for it.
+//
+// Jobs are stored by a Driver, in the database or in Valkey/Redis, and
+// delivered at least once: a worker leases a job, and if the lease runs out
+// before the worker reports back, for instance because the process died,
+// the job is handed out again. Handlers must therefore be idempotent.
+// Failed jobs are retried with exponential backoff until they run out of
+// attempts; then they stay in the failed list until an admin retries or
+// deletes them. Completed jobs are removed.
+package jobqueue
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultQueue is the queue jobs go to unless InQueue says otherwise.
+const DefaultQueue = "default"
+
+// Status is the state of a stored job.
+type Status string
+
+// Job states.
+const (
+	StatusPending Status = "pending" // Waiting for its run time or a free worker
+	StatusRunning Status = "running" // Leased by a worker
+	StatusFailed  Status = "failed"  // Out of attempts, or failed permanently
+)
+
+// Errors returned by drivers.
+var (
+	// ErrJobNotFound is returned when a job does not exist or is not in a
+	// state the operation applies to.
+	ErrJobNotFound = errors.New("job not found")
+	// ErrLeaseLost is returned when a worker reports on a job whose lease
+	// ran out and which was handed to another worker.
+	ErrLeaseLost = errors.New("job lease lost")
+	// ErrNoQueue is returned by a nil *Queue, i.e. when the job queue is
+	// not configured.
+	ErrNoQueue = errors.New("job queue not configured")
+)
+
+// Job is a unit of background work.
+type Job struct {
+	ID          string          `json:"id"`
+	Queue       string          `json:"queue"`
+	Type        string          `json:"type"`
+	Payload     json.RawMessage `json:"payload"`
+	Status      Status          `json:"status"`
+	Attempts    int             `json:"attempts"`
+	MaxAttempts int             `json:"max_attempts"`
+	RunAt       time.Time       `json:"run_at"`
+	LockedUntil *time.Time      `json:"locked_until,omitempty"`
+	LastError   string          `json:"last_error,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+
+	// Token identifies the current lease. Drivers only accept reports
+	// carrying the token of the latest lease.
+	Token string `json:"-"`
+}
+
+// Decode unmarshals the job payload into v.
+func (j *Job) Decode(v interface{}) error {
+	if err := json.Unmarshal(j.Payload, v); err != nil {
+		return Permanent(fmt.Errorf("decode %s payload: %w", j.Type, err))
+	}
+	return nil
+}
+
+// QueueStats counts the jobs of one queue by state.
+type QueueStats struct {
+	Queue     string `json:"queue"`
+	Pending   int    `json:"pending"`   // Due now
+	Scheduled int    `json:"scheduled"` // Due later
+	Running   int    `json:"running"`
+	Failed    int    `json:"failed"`
+}
+
+// Depth is the number of jobs waiting to run now.
+func (s QueueStats) Depth() int {
+	return s.Pending
+}
+
+// Driver stores jobs. Implementations must make Reserve safe to call from
+// several processes at once.
+type Driver interface {
+	// Enqueue stores a new pending job.
+	Enqueue(ctx context.Context, job *Job) error
+	// Reserve leases the next due job of queue until now+lease, counting an
+	// attempt, or returns nil when no job is due. Running jobs whose lease
+	// ran out are due again.
+	Reserve(ctx context.Context, queue string, lease time.Duration) (*Job, error)
+	// Complete removes a finished job.
+	Complete(ctx context.Context, job *Job) error
+	// Retry returns a failed attempt to the pending jobs, due at runAt.
+	Retry(ctx context.Context, job *Job, runAt time.Time, lastError string) error
+	// Fail moves a job to the failed list.
+	Fail(ctx context.Context, job *Job, lastError string) error
+	// Stats counts the jobs of every queue that has any.
+	Stats(ctx context.Context) ([]QueueStats, error)
+	// Failed lists failed jobs, most recent first. An empty queue lists
+	// those of every queue.
+	Failed(ctx context.Context, queue string, limit int) ([]*Job, error)
+	// Requeue makes a failed job due now with its attempts reset.
+	Requeue(ctx context.Context, id string) error
+	// Delete removes a pending or failed job.
+	Delete(ctx context.Context, id string) error
+}
+
+// Handler processes one job. Returning an error retries the job, unless
+// the error is wrapped with Permanent.
+type Handler func(ctx context.Context, job *Job) error
+
+type permanentError struct{ err error }
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent marks err as not worth retrying, e.g. for an invalid payload.
+// The job goes straight to the failed list.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// IsPermanent reports whether err was marked with Permanent.
+func IsPermanent(err error) bool {
+	var p *permanentError
+	return errors.As(err, &p)
+}
+
+// EnqueueOption adjusts a job before it is stored.
+type EnqueueOption func(*Job)
+
+// InQueue puts the job in the named queue.
+func InQueue(name string) EnqueueOption {
+	return func(j *Job) { j.Queue = name }
+}
+
+// Delay runs the job no earlier than d from now.
+func Delay(d time.Duration) EnqueueOption {
+	return func(j *Job) { j.RunAt = j.CreatedAt.Add(d) }
+}
+
+// At runs the job no earlier than t.
+func At(t time.Time) EnqueueOption {
+	return func(j *Job) { j.RunAt = t }
+}
+
+// MaxAttempts overrides how often the job is tried.
+func MaxAttempts(n int) EnqueueOption {
+	return func(j *Job) { j.MaxAttempts = n }
+}
+
+var defaultQueue atomic.Pointer[Queue]
+
+// SetDefault makes q the queue returned by Default.
+func SetDefault(q *Queue) {
+	defaultQueue.Store(q)
+}
+
+// Default returns the application's queue, or nil when the job queue is
+// not configured. Enqueue on a nil *Queue returns ErrNoQueue, so callers
+// can fall back to doing the work inline.
+func Default() *Queue {
+	return defaultQueue.Load()
+}
+
+func newJobID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate job id: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,291 @@
+package jobqueue
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/testutil"
+)
+
+func newTestQueue(t *testing.T, opts Options) (*Queue, *DBDriver) {
+	t.Helper()
+	driver := NewDBDriver(testutil.MigratedDB(t))
+	if opts.Backoff == nil {
+		opts.Backoff = func(int) time.Duration { return 0 }
+	}
+	return New(driver, opts), driver
+}
+
+func stats(t *testing.T, q *Queue) map[string]QueueStats {
+	t.Helper()
+	list, err := q.Stats(context.Background())
+	require.NoError(t, err)
+	out := map[string]QueueStats{}
+	for _, s := range list {
+		out[s.Queue] = s
+	}
+	return out
+}
+
+func TestQueue_RunsAndRemovesJob(t *testing.T) {
+	q, _ := newTestQueue(t, Options{})
+	ctx := context.Background()
+
+	type mail struct {
+		To string `json:"to"`
+	}
+	var got mail
+	q.Handle("mail.send", func(ctx context.Context, job *Job) error {
+		return job.Decode(&got)
+	})
+
+	job, err := q.Enqueue(ctx, "mail.send", mail{To: "ops@example.com"})
+	require.NoError(t, err)
+	assert.Equal(t, DefaultQueue, job.Queue)
+	assert.Equal(t, 1, stats(t, q)[DefaultQueue].Pending)
+
+	require.True(t, q.RunNext(ctx))
+	assert.Equal(t, "ops@example.com", got.To)
+	assert.False(t, q.RunNext(ctx), "a completed job is gone")
+	assert.Empty(t, stats(t, q))
+}
+
+func TestQueue_RetriesWithBackoffThenFails(t *testing.T) {
+	var attempts []int
+	q, _ := newTestQueue(t, Options{
+		MaxAttempts: 3,
+		Backoff: func(attempt int) time.Duration {
+			attempts = append(attempts, attempt)
+			return 0
+		},
+	})
+	ctx := context.Background()
+	q.Handle("webhook.deliver", func(ctx context.Context, job *Job) error {
+		return errors.New("endpoint returned 502")
+	})
+	job, err := q.Enqueue(ctx, "webhook.deliver", map[string]int{"webhook_id": 4})
+	require.NoError(t, err)
+
+	for q.RunNext(ctx) {
+	}
+	assert.Equal(t, []int{1, 2}, attempts, "backoff is asked after every failed attempt but the last")
+
+	failed, err := q.Failed(ctx, "", 10)
+	require.NoError(t, err)
+	require.Len(t, failed, 1)
+	assert.Equal(t, job.ID, failed[0].ID)
+	assert.Equal(t, StatusFailed, failed[0].Status)
+	assert.Equal(t, 3, failed[0].Attempts)
+	assert.Equal(t, "endpoint returned 502", failed[0].LastError)
+	assert.JSONEq(t, `{"webhook_id":4}`, string(failed[0].Payload))
+	assert.Equal(t, 1, stats(t, q)[DefaultQueue].Failed)
+
+	// An admin retries it once the endpoint is back.
+	q.Handle("webhook.deliver", func(ctx context.Context, job *Job) error { return nil })
+	require.NoError(t, q.Requeue(ctx, job.ID))
+	assert.ErrorIs(t, q.Requeue(ctx, job.ID), ErrJobNotFound, "only failed jobs can be retried")
+	require.True(t, q.RunNext(ctx))
+	assert.Empty(t, stats(t, q))
+}
+
+func TestQueue_BackoffDelaysRetry(t *testing.T) {
+	q, _ := newTestQueue(t, Options{Backoff: func(int) time.Duration { return time.Hour }})
+	ctx := context.Background()
+	q.Handle("report.generate", func(ctx context.Context, job *Job) error { return errors.New("busy") })
+	_, err := q.Enqueue(ctx, "report.generate", nil)
+	require.NoError(t, err)
+
+	require.True(t, q.RunNext(ctx))
+	assert.False(t, q.RunNext(ctx), "the retry is not due yet")
+	s := stats(t, q)[DefaultQueue]
+	assert.Equal(t, 0, s.Pending)
+	assert.Equal(t, 1, s.Scheduled)
+}
+
+func TestQueue_PermanentErrorsAndMissingHandlers(t *testing.T) {
+	q, _ := newTestQueue(t, Options{MaxAttempts: 5})
+	ctx := context.Background()
+	q.Handle("import.users", func(ctx context.Context, job *Job) error {
+		var rows []string
+		return job.Decode(&rows)
+	})
+	_, err := q.Enqueue(ctx, "import.users", map[string]string{"not": "a list"})
+	require.NoError(t, err)
+	_, err = q.Enqueue(ctx, "unknown.type", nil)
+	require.NoError(t, err)
+
+	for q.RunNext(ctx) {
+	}
+	failed, err := q.Failed(ctx, DefaultQueue, 10)
+	require.NoError(t, err)
+	require.Len(t, failed, 2)
+	for _, job := range failed {
+		assert.Equal(t, 1, job.Attempts, job.Type)
+	}
+}
+
+func TestQueue_PanicIsRetried(t *testing.T) {
+	q, _ := newTestQueue(t, Options{})
+	ctx := context.Background()
+	var calls int
+	q.Handle("flaky", func(ctx context.Context, job *Job) error {
+		calls++
+		if calls == 1 {
+			panic("nil map")
+		}
+		return nil
+	})
+	_, err := q.Enqueue(ctx, "flaky", nil)
+	require.NoError(t, err)
+
+	require.True(t, q.RunNext(ctx))
+	require.True(t, q.RunNext(ctx))
+	assert.Equal(t, 2, calls)
+	assert.Empty(t, stats(t, q))
+}
+
+func TestQueue_ScheduledJobsAndQueuePriority(t *testing.T) {
+	q, _ := newTestQueue(t, Options{Queues: []string{"mail", "reports"}})
+	ctx := context.Background()
+	var order []string
+	record := func(ctx context.Context, job *Job) error {
+		order = append(order, job.Queue)
+		return nil
+	}
+	q.Handle("work", record)
+
+	_, err := q.Enqueue(ctx, "work", nil, InQueue("reports"))
+	require.NoError(t, err)
+	_, err = q.Enqueue(ctx, "work", nil, InQueue("mail"))
+	require.NoError(t, err)
+	_, err = q.Enqueue(ctx, "work", nil, InQueue("mail"), Delay(time.Hour))
+	require.NoError(t, err)
+	_, err = q.Enqueue(ctx, "work", nil, InQueue("other"))
+	require.NoError(t, err)
+
+	for q.RunNext(ctx) {
+	}
+	assert.Equal(t, []string{"mail", "reports"}, order)
+	s := stats(t, q)
+	assert.Equal(t, 1, s["mail"].Scheduled)
+	assert.Equal(t, 1, s["other"].Pending, "queues without workers are left alone")
+}
+
+func TestDBDriver_ExpiredLeaseIsHandedOutAgain(t *testing.T) {
+	q, driver := newTestQueue(t, Options{})
+	ctx := context.Background()
+	job, err := q.Enqueue(ctx, "slow", nil, MaxAttempts(2))
+	require.NoError(t, err)
+
+	first, err := driver.Reserve(ctx, DefaultQueue, -time.Second)
+	require.NoError(t, err)
+	require.NotNil(t, first)
+	assert.Equal(t, job.ID, first.ID)
+	assert.Equal(t, StatusRunning, first.Status)
+	assert.Equal(t, 1, first.Attempts)
+
+	second, err := driver.Reserve(ctx, DefaultQueue, time.Minute)
+	require.NoError(t, err)
+	require.NotNil(t, second, "the first worker's lease ran out")
+	assert.Equal(t, 2, second.Attempts)
+	assert.NotEqual(t, first.Token, second.Token)
+
+	assert.ErrorIs(t, driver.Complete(ctx, first), ErrLeaseLost)
+	none, err := driver.Reserve(ctx, DefaultQueue, time.Minute)
+	require.NoError(t, err)
+	assert.Nil(t, none, "a live lease is not handed out")
+	assert.ErrorIs(t, driver.Delete(ctx, job.ID), ErrJobNotFound, "running jobs cannot be deleted")
+	require.NoError(t, driver.Complete(ctx, second))
+}
+
+func TestQueue_GivesUpWhenLeasesKeepExpiring(t *testing.T) {
+	q, driver := newTestQueue(t, Options{})
+	ctx := context.Background()
+	q.Handle("crashy", func(ctx context.Context, job *Job) error { return nil })
+	_, err := q.Enqueue(ctx, "crashy", nil, MaxAttempts(1))
+	require.NoError(t, err)
+
+	// A worker died while running the only attempt.
+	_, err = driver.Reserve(ctx, DefaultQueue, -time.Second)
+	require.NoError(t, err)
+
+	require.True(t, q.RunNext(ctx))
+	failed, err := q.Failed(ctx, "", 10)
+	require.NoError(t, err)
+	require.Len(t, failed, 1)
+	assert.Contains(t, failed[0].LastError, "did not finish within its lease")
+}
+
+func TestQueue_DeletePendingJob(t *testing.T) {
+	q, _ := newTestQueue(t, Options{})
+	ctx := context.Background()
+	job, err := q.Enqueue(ctx, "report.generate", nil, At(time.Now().Add(time.Hour)))
+	require.NoError(t, err)
+
+	require.NoError(t, q.Delete(ctx, job.ID))
+	assert.ErrorIs(t, q.Delete(ctx, job.ID), ErrJobNotFound)
+	assert.Empty(t, stats(t, q))
+}
+
+func TestQueue_RunProcessesEachJobOnce(t *testing.T) {
+	q, _ := newTestQueue(t, Options{Workers: 4, PollInterval: 10 * time.Millisecond})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	const jobs = 20
+	var mu sync.Mutex
+	seen := map[string]int{}
+	var done atomic.Int32
+	q.Handle("count", func(ctx context.Context, job *Job) error {
+		mu.Lock()
+		seen[job.ID]++
+		mu.Unlock()
+		done.Add(1)
+		return nil
+	})
+
+	stopped := make(chan struct{})
+	go func() {
+		_ = q.Run(ctx)
+		close(stopped)
+	}()
+	for i := 0; i < jobs; i++ {
+		_, err := q.Enqueue(ctx, "count", i)
+		require.NoError(t, err)
+	}
+
+	require.Eventually(t, func() bool { return done.Load() == jobs }, 5*time.Second, 10*time.Millisecond)
+	cancel()
+	<-stopped
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Len(t, seen, jobs)
+	for id, n := range seen {
+		assert.Equal(t, 1, n, id)
+	}
+}
+
+func TestNilQueue(t *testing.T) {
+	var q *Queue
+	_, err := q.Enqueue(context.Background(), "mail.send", nil)
+	assert.ErrorIs(t, err, ErrNoQueue)
+	_, err = q.Stats(context.Background())
+	assert.ErrorIs(t, err, ErrNoQueue)
+}
+
+func TestExponentialBackoff(t *testing.T) {
+	backoff := ExponentialBackoff(10*time.Second, time.Minute)
+	for attempt, want := range map[int]time.Duration{1: 10 * time.Second, 2: 20 * time.Second, 3: 40 * time.Second, 4: time.Minute, 10: time.Minute} {
+		got := backoff(attempt)
+		assert.GreaterOrEqual(t, got, want-want/5, "attempt %d", attempt)
+		assert.LessOrEqual(t, got, want+want/5, "attempt %d", attempt)
+	}
+}
@@ -0,0 +1,301 @@
+package jobqueue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// Options configures a Queue. Zero values take the defaults.
+type Options struct {
+	// Workers is the number of jobs run at once (default 4).
+	Workers int
+	// Queues are the queues the workers take jobs from, in order of
+	// priority (default: DefaultQueue).
+	Queues []string
+	// PollInterval is how often idle workers look for due jobs (default
+	// 1s). Jobs enqueued through this Queue wake a worker at once.
+	PollInterval time.Duration
+	// Lease is how long a job may run before it is handed to another
+	// worker (default 5m). It is also the handler's timeout.
+	Lease time.Duration
+	// MaxAttempts is how often a job is tried unless it says otherwise
+	// (default 5).
+	MaxAttempts int
+	// Backoff returns the delay before the next try after the given
+	// number of failed attempts (default: exponential from 10s, capped at
+	// one hour, with jitter).
+	Backoff func(attempt int) time.Duration
+}
+
+// Queue enqueues jobs and runs them with a pool of workers.
+type Queue struct {
+	driver Driver
+	opts   Options
+	wake   chan struct{}
+
+	mu       sync.RWMutex
+	handlers map[string]Handler
+}
+
+// New creates a queue storing jobs with driver.
+func New(driver Driver, opts Options) *Queue {
+	if opts.Workers <= 0 {
+		opts.Workers = 4
+	}
+	if len(opts.Queues) == 0 {
+		opts.Queues = []string{DefaultQueue}
+	}
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = time.Second
+	}
+	if opts.Lease <= 0 {
+		opts.Lease = 5 * time.Minute
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 5
+	}
+	if opts.Backoff == nil {
+		opts.Backoff = ExponentialBackoff(10*time.Second, time.Hour)
+	}
+	return &Queue{
+		driver:   driver,
+		opts:     opts,
+		wake:     make(chan struct{}, 1),
+		handlers: make(map[string]Handler),
+	}
+}
+
+// ExponentialBackoff doubles the delay from base with every attempt, up to
+// max, and spreads retries by up to a fifth either way so failed jobs do
+// not all come back at once.
+func ExponentialBackoff(base, max time.Duration) func(attempt int) time.Duration {
+	return func(attempt int) time.Duration {
+		d := base
+		for i := 1; i < attempt && d < max; i++ {
+			d *= 2
+		}
+		if d > max {
+			d = max
+		}
+		jitter := time.Duration(rand.Int64N(int64(d)/5+1)) * 2
+		return d - d/5 + jitter
+	}
+}
+
+// Handle registers the handler for jobs of jobType. Jobs without a handler
+// fail permanently, so register handlers before running the queue.
+func (q *Queue) Handle(jobType string, h Handler) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.handlers[jobType] = h
+}
+
+func (q *Queue) handler(jobType string) Handler {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	return q.handlers[jobType]
+}
+
+// Enqueue stores a job of jobType with payload marshaled as JSON.
+func (q *Queue) Enqueue(ctx context.Context, jobType string, payload interface{}, opts ...EnqueueOption) (*Job, error) {
+	if q == nil {
+		return nil, ErrNoQueue
+	}
+	if jobType == "" {
+		return nil, fmt.Errorf("job type is required")
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("marshal %s payload: %w", jobType, err)
+	}
+	id, err := newJobID()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	job := &Job{
+		ID:          id,
+		Queue:       DefaultQueue,
+		Type:        jobType,
+		Payload:     data,
+		Status:      StatusPending,
+		MaxAttempts: q.opts.MaxAttempts,
+		RunAt:       now,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	for _, opt := range opts {
+		opt(job)
+	}
+	if job.MaxAttempts <= 0 {
+		job.MaxAttempts = 1
+	}
+	if err := q.driver.Enqueue(ctx, job); err != nil {
+		return nil, fmt.Errorf("enqueue %s job: %w", jobType, err)
+	}
+	if !job.RunAt.After(now) {
+		select {
+		case q.wake <- struct{}{}:
+		default:
+		}
+	}
+	return job, nil
+}
+
+// Stats counts the jobs of every queue.
+func (q *Queue) Stats(ctx context.Context) ([]QueueStats, error) {
+	if q == nil {
+		return nil, ErrNoQueue
+	}
+	return q.driver.Stats(ctx)
+}
+
+// Failed lists failed jobs, most recent first.
+func (q *Queue) Failed(ctx context.Context, queue string, limit int) ([]*Job, error) {
+	if q == nil {
+		return nil, ErrNoQueue
+	}
+	return q.driver.Failed(ctx, queue, limit)
+}
+
+// Requeue makes a failed job due now with its attempts reset.
+func (q *Queue) Requeue(ctx context.Context, id string) error {
+	if q == nil {
+		return ErrNoQueue
+	}
+	if err := q.driver.Requeue(ctx, id); err != nil {
+		return err
+	}
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Delete removes a pending or failed job.
+func (q *Queue) Delete(ctx context.Context, id string) error {
+	if q == nil {
+		return ErrNoQueue
+	}
+	return q.driver.Delete(ctx, id)
+}
+
+// Run starts the workers and blocks until ctx is done and every running job
+// has been reported.
+func (q *Queue) Run(ctx context.Context) error {
+	if q == nil {
+		return ErrNoQueue
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < q.opts.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			q.work(ctx)
+		}()
+	}
+	wg.Wait()
+	return nil
+}
+
+// work takes jobs until ctx is done, sleeping while none is due.
+func (q *Queue) work(ctx context.Context) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-q.wake:
+		case <-timer.C:
+		}
+		for ctx.Err() == nil && q.RunNext(ctx) {
+		}
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(q.opts.PollInterval)
+	}
+}
+
+// RunNext runs the next due job of the first queue that has one and
+// reports whether there was one. Workers call it in a loop; tests can call
+// it directly.
+func (q *Queue) RunNext(ctx context.Context) bool {
+	for _, name := range q.opts.Queues {
+		job, err := q.driver.Reserve(ctx, name, q.opts.Lease)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("jobqueue: reserve from %s failed: %v", name, err)
+			}
+			return false
+		}
+		if job != nil {
+			q.process(ctx, job)
+			return true
+		}
+	}
+	return false
+}
+
+// process runs job and records the outcome. The report is made even when
+// ctx was cancelled meanwhile, so shutdown does not leave jobs leased.
+func (q *Queue) process(ctx context.Context, job *Job) {
+	report := context.WithoutCancel(ctx)
+
+	if job.Attempts > job.MaxAttempts {
+		q.fail(report, job, fmt.Sprintf("gave up after %d attempts; the last one did not finish within its lease", job.MaxAttempts))
+		return
+	}
+	h := q.handler(job.Type)
+	if h == nil {
+		q.fail(report, job, fmt.Sprintf("no handler registered for job type %q", job.Type))
+		return
+	}
+
+	runCtx, cancel := context.WithTimeout(ctx, q.opts.Lease)
+	err := q.call(runCtx, h, job)
+	cancel()
+
+	switch {
+	case err == nil:
+		if err := q.driver.Complete(report, job); err != nil {
+			log.Printf("jobqueue: complete %s job %s failed: %v", job.Type, job.ID, err)
+		}
+	case IsPermanent(err) || job.Attempts >= job.MaxAttempts:
+		q.fail(report, job, err.Error())
+	default:
+		runAt := time.Now().Add(q.opts.Backoff(job.Attempts))
+		if err := q.driver.Retry(report, job, runAt, err.Error()); err != nil {
+			log.Printf("jobqueue: reschedule %s job %s failed: %v", job.Type, job.ID, err)
+		}
+	}
+}
+
+func (q *Queue) fail(ctx context.Context, job *Job, msg string) {
+	log.Printf("jobqueue: %s job %s failed: %s", job.Type, job.ID, msg)
+	if err := q.driver.Fail(ctx, job, msg); err != nil {
+		log.Printf("jobqueue: mark %s job %s failed: %v", job.Type, job.ID, err)
+	}
+}
+
+// call runs h, turning a panic into an error.
+func (q *Queue) call(ctx context.Context, h Handler, job *Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("jobqueue: %s job %s panicked: %v\n%s", job.Type, job.ID, r, debug.Stack())
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return h(ctx, job)
+}
@@ -0,0 +1,330 @@
+package jobqueue
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisDriver stores jobs in Valkey or Redis. Each job is a hash; sorted
+// sets per queue order the pending jobs by run time, the running ones by
+// lease expiry and the failed ones by failure time. State changes run as
+// Lua scripts, so they are atomic across processes.
+type RedisDriver struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+// NewRedisDriver creates a driver using client. Keys start with prefix,
+// "goatflow:jobs:" when empty.
+func NewRedisDriver(client redis.UniversalClient, prefix string) *RedisDriver {
+	if prefix == "" {
+		prefix = "goatflow:jobs:"
+	}
+	return &RedisDriver{client: client, prefix: prefix}
+}
+
+func (d *RedisDriver) jobKey(id string) string { return d.prefix + "job:" + id }
+func (d *RedisDriver) queuesKey() string       { return d.prefix + "queues" }
+func (d *RedisDriver) setKey(queue, set string) string {
+	return d.prefix + "queue:" + queue + ":" + set
+}
+
+func millis(t time.Time) int64 { return t.UnixMilli() }
+
+// Enqueue implements Driver.
+func (d *RedisDriver) Enqueue(ctx context.Context, job *Job) error {
+	_, err := d.client.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		p.HSet(ctx, d.jobKey(job.ID), map[string]interface{}{
+			"id":           job.ID,
+			"queue":        job.Queue,
+			"type":         job.Type,
+			"payload":      string(job.Payload),
+			"status":       string(job.Status),
+			"attempts":     job.Attempts,
+			"max_attempts": job.MaxAttempts,
+			"run_at":       millis(job.RunAt),
+			"created_at":   millis(job.CreatedAt),
+			"updated_at":   millis(job.UpdatedAt),
+		})
+		p.ZAdd(ctx, d.setKey(job.Queue, "pending"), redis.Z{Score: float64(millis(job.RunAt)), Member: job.ID})
+		p.SAdd(ctx, d.queuesKey(), job.Queue)
+		return nil
+	})
+	return err
+}
+
+// reserveScript leases the oldest expired running job or else the first
+// due pending job.
+// KEYS: pending, running. ARGV: now, lease deadline, token, job key prefix.
+var reserveScript = redis.NewScript(`
+local ids = redis.call('ZRANGEBYSCORE', KEYS[2], '-inf', ARGV[1], 'LIMIT', 0, 1)
+if #ids == 0 then
+	ids = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, 1)
+	if #ids == 0 then
+		return false
+	end
+	redis.call('ZREM', KEYS[1], ids[1])
+end
+local id = ids[1]
+local key = ARGV[4] .. id
+if redis.call('EXISTS', key) == 0 then
+	redis.call('ZREM', KEYS[2], id)
+	return false
+end
+redis.call('ZADD', KEYS[2], ARGV[2], id)
+redis.call('HINCRBY', key, 'attempts', 1)
+redis.call('HSET', key, 'status', 'running', 'locked_until', ARGV[2], 'token', ARGV[3], 'updated_at', ARGV[1])
+return id
+`)
+
+// Reserve implements Driver.
+func (d *RedisDriver) Reserve(ctx context.Context, queue string, lease time.Duration) (*Job, error) {
+	token, err := newJobID()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	id, err := reserveScript.Run(ctx, d.client,
+		[]string{d.setKey(queue, "pending"), d.setKey(queue, "running")},
+		millis(now), millis(now.Add(lease)), token, d.prefix+"job:").Text()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reserve job: %w", err)
+	}
+	return d.get(ctx, id)
+}
+
+func (d *RedisDriver) get(ctx context.Context, id string) (*Job, error) {
+	fields, err := d.client.HGetAll(ctx, d.jobKey(id)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("load job: %w", err)
+	}
+	if len(fields) == 0 {
+		return nil, ErrJobNotFound
+	}
+	return redisJob(fields), nil
+}
+
+func redisJob(f map[string]string) *Job {
+	atoi := func(k string) int { n, _ := strconv.Atoi(f[k]); return n }
+	at := func(k string) time.Time {
+		ms, _ := strconv.ParseInt(f[k], 10, 64)
+		return time.UnixMilli(ms)
+	}
+	job := &Job{
+		ID:          f["id"],
+		Queue:       f["queue"],
+		Type:        f["type"],
+		Payload:     []byte(f["payload"]),
+		Status:      Status(f["status"]),
+		Attempts:    atoi("attempts"),
+		MaxAttempts: atoi("max_attempts"),
+		RunAt:       at("run_at"),
+		LastError:   f["last_error"],
+		CreatedAt:   at("created_at"),
+		UpdatedAt:   at("updated_at"),
+		Token:       f["token"],
+	}
+	if f["locked_until"] != "" {
+		t := at("locked_until")
+		job.LockedUntil = &t
+	}
+	return job
+}
+
+// releaseScript ends a lease: it deletes the job (status "done"), or
+// moves it to the pending or failed set. It does nothing when the token no
+// longer matches, i.e. the job was leased again.
+// KEYS: job, running, target set. ARGV: token, status, score, run_at, last_error, now.
+var releaseScript = redis.NewScript(`
+if redis.call('HGET', KEYS[1], 'token') ~= ARGV[1] then
+	return 0
+end
+local id = redis.call('HGET', KEYS[1], 'id')
+redis.call('ZREM', KEYS[2], id)
+if ARGV[2] == 'done' then
+	redis.call('DEL', KEYS[1])
+	return 1
+end
+redis.call('HDEL', KEYS[1], 'token', 'locked_until')
+redis.call('HSET', KEYS[1], 'status', ARGV[2], 'run_at', ARGV[4], 'last_error', ARGV[5], 'updated_at', ARGV[6])
+redis.call('ZADD', KEYS[3], ARGV[3], id)
+return 1
+`)
+
+func (d *RedisDriver) release(ctx context.Context, job *Job, status string, target string, score, runAt time.Time, lastError string) error {
+	n, err := releaseScript.Run(ctx, d.client,
+		[]string{d.jobKey(job.ID), d.setKey(job.Queue, "running"), d.setKey(job.Queue, target)},
+		job.Token, status, millis(score), millis(runAt), lastError, millis(time.Now())).Int()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrLeaseLost
+	}
+	return nil
+}
+
+// Complete implements Driver.
+func (d *RedisDriver) Complete(ctx context.Context, job *Job) error {
+	return d.release(ctx, job, "done", "pending", time.Now(), time.Now(), "")
+}
+
+// Retry implements Driver.
+func (d *RedisDriver) Retry(ctx context.Context, job *Job, runAt time.Time, lastError string) error {
+	return d.release(ctx, job, string(StatusPending), "pending", runAt, runAt, lastError)
+}
+
+// Fail implements Driver.
+func (d *RedisDriver) Fail(ctx context.Context, job *Job, lastError string) error {
+	return d.release(ctx, job, string(StatusFailed), "failed", time.Now(), job.RunAt, lastError)
+}
+
+// Stats implements Driver.
+func (d *RedisDriver) Stats(ctx context.Context) ([]QueueStats, error) {
+	queues, err := d.client.SMembers(ctx, d.queuesKey()).Result()
+	if err != nil {
+		return nil, fmt.Errorf("list queues: %w", err)
+	}
+	sort.Strings(queues)
+	now := strconv.FormatInt(millis(time.Now()), 10)
+
+	type counts struct{ pending, scheduled, running, failed *redis.IntCmd }
+	all := make([]counts, len(queues))
+	_, err = d.client.Pipelined(ctx, func(p redis.Pipeliner) error {
+		for i, q := range queues {
+			all[i] = counts{
+				pending:   p.ZCount(ctx, d.setKey(q, "pending"), "-inf", now),
+				scheduled: p.ZCount(ctx, d.setKey(q, "pending"), "("+now, "+inf"),
+				running:   p.ZCard(ctx, d.setKey(q, "running")),
+				failed:    p.ZCard(ctx, d.setKey(q, "failed")),
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("count jobs: %w", err)
+	}
+
+	var stats []QueueStats
+	for i, q := range queues {
+		s := QueueStats{
+			Queue:     q,
+			Pending:   int(all[i].pending.Val()),
+			Scheduled: int(all[i].scheduled.Val()),
+			Running:   int(all[i].running.Val()),
+			Failed:    int(all[i].failed.Val()),
+		}
+		if s.Pending+s.Scheduled+s.Running+s.Failed > 0 {
+			stats = append(stats, s)
+		}
+	}
+	return stats, nil
+}
+
+// Failed implements Driver.
+func (d *RedisDriver) Failed(ctx context.Context, queue string, limit int) ([]*Job, error) {
+	queues := []string{queue}
+	if queue == "" {
+		var err error
+		if queues, err = d.client.SMembers(ctx, d.queuesKey()).Result(); err != nil {
+			return nil, fmt.Errorf("list queues: %w", err)
+		}
+	}
+	var jobs []*Job
+	for _, q := range queues {
+		ids, err := d.client.ZRevRange(ctx, d.setKey(q, "failed"), 0, int64(limit)-1).Result()
+		if err != nil {
+			return nil, fmt.Errorf("list failed jobs: %w", err)
+		}
+		for _, id := range ids {
+			job, err := d.get(ctx, id)
+			if err == ErrJobNotFound {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			jobs = append(jobs, job)
+		}
+	}
+	sort.SliceStable(jobs, func(i, j int) bool { return jobs[i].UpdatedAt.After(jobs[j].UpdatedAt) })
+	if len(jobs) > limit {
+		jobs = jobs[:limit]
+	}
+	return jobs, nil
+}
+
+// requeueScript moves a failed job back to the pending set.
+// KEYS: job, failed, pending. ARGV: now.
+var requeueScript = redis.NewScript(`
+local id = redis.call('HGET', KEYS[1], 'id')
+if not id or redis.call('ZREM', KEYS[2], id) == 0 then
+	return 0
+end
+redis.call('HSET', KEYS[1], 'status', 'pending', 'attempts', 0, 'run_at', ARGV[1], 'updated_at', ARGV[1])
+redis.call('ZADD', KEYS[3], ARGV[1], id)
+return 1
+`)
+
+// Requeue implements Driver.
+func (d *RedisDriver) Requeue(ctx context.Context, id string) error {
+	queue, err := d.client.HGet(ctx, d.jobKey(id), "queue").Result()
+	if err == redis.Nil {
+		return ErrJobNotFound
+	}
+	if err != nil {
+		return err
+	}
+	n, err := requeueScript.Run(ctx, d.client,
+		[]string{d.jobKey(id), d.setKey(queue, "failed"), d.setKey(queue, "pending")},
+		millis(time.Now())).Int()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrJobNotFound
+	}
+	return nil
+}
+
+// deleteScript removes a job that is pending or failed.
+// KEYS: job, pending, failed.
+var deleteScript = redis.NewScript(`
+local id = redis.call('HGET', KEYS[1], 'id')
+if not id then
+	return 0
+end
+if redis.call('ZREM', KEYS[2], id) + redis.call('ZREM', KEYS[3], id) == 0 then
+	return 0
+end
+redis.call('DEL', KEYS[1])
+return 1
+`)
+
+// Delete implements Driver.
+func (d *RedisDriver) Delete(ctx context.Context, id string) error {
+	queue, err := d.client.HGet(ctx, d.jobKey(id), "queue").Result()
+	if err == redis.Nil {
+		return ErrJobNotFound
+	}
+	if err != nil {
+		return err
+	}
+	n, err := deleteScript.Run(ctx, d.client,
+		[]string{d.jobKey(id), d.setKey(queue, "pending"), d.setKey(queue, "failed")}).Int()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrJobNotFound
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+package ldap
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/goatkit/goatflow/internal/config"
+)
+
+// Frontends a directory can serve.
+const (
+	FrontendAgent    = "agent"
+	FrontendCustomer = "customer"
+)
+
+// GroupMapping grants a GoatFlow group permission to members of an LDAP group.
+type GroupMapping struct {
+	LDAPGroup  string
+	Group      string
+	Permission string
+}
+
+// Directory is one configured LDAP backend: how to reach it, which login
+// page it serves and how its users become GoatFlow accounts.
+type Directory struct {
+	Name          string
+	Frontend      string
+	Config        *Config
+	GroupMappings []GroupMapping
+	AutoCreate    bool
+	Sync          bool
+
+	dial func(host string) (Conn, error) // Overrides the network dial in tests
+}
+
+// NewProvider returns a provider for the directory.
+func (d *Directory) NewProvider() *Provider {
+	p := NewProvider(d.Config)
+	if d.dial != nil {
+		p.dial = d.dial
+	}
+	return p
+}
+
+// Directories is an ordered list of directories.
+type Directories []*Directory
+
+// ForFrontend returns the directories serving the agent or customer login.
+func (ds Directories) ForFrontend(frontend string) Directories {
+	var out Directories
+	for _, d := range ds {
+		if d.Frontend == frontend {
+			out = append(out, d)
+		}
+	}
+	return out
+}
+
+// Authenticate tries the frontend's directories in order. Directories that
+// do not know the user or cannot be reached pass on to the next one; a wrong
+// password for a known user ends the search, so one person cannot be
+// signed in through another directory's account of the same name.
+func (ds Directories) Authenticate(frontend, username, password string) (*Directory, *User, error) {
+	unavailable := false
+	for _, d := range ds.ForFrontend(frontend) {
+		user, err := d.NewProvider().Verify(username, password)
+		switch {
+		case err == nil:
+			return d, user, nil
+		case errors.Is(err, ErrUserNotFound):
+			continue
+		case errors.Is(err, ErrInvalidCredentials):
+			return nil, nil, err
+		default:
+			unavailable = true
+		}
+	}
+	if unavailable {
+		return nil, nil, ErrUnavailable
+	}
+	return nil, nil, ErrUserNotFound
+}
+
+// DirectoriesFromConfig builds the configured directories. Entries with
+// problems are left out and reported in the returned messages, so one
+// broken directory does not take the others down.
+func DirectoriesFromConfig(cfgs []config.LDAPDirectoryConfig) (Directories, []string) {
+	var dirs Directories
+	var problems []string
+	for i, c := range cfgs {
+		name := c.Name
+		if name == "" {
+			name = fmt.Sprintf("directory %d", i+1)
+		}
+		d, errs := directoryFromConfig(name, c)
+		if len(errs) > 0 {
+			problems = append(problems, fmt.Sprintf("%s: %s", name, strings.Join(errs, "; ")))
+			continue
+		}
+		dirs = append(dirs, d)
+	}
+	return dirs, problems
+}
+
+func directoryFromConfig(name string, c config.LDAPDirectoryConfig) (*Directory, []string) {
+	cfg := &Config{}
+	if c.Type != "" {
+		template, err := GetConfigTemplate(c.Type)
+		if err != nil {
+			return nil, []string{err.Error()}
+		}
+		cfg = template
+	}
+
+	cfg.Host = c.Host
+	cfg.FailoverHosts = c.FailoverHosts
+	if c.Port != 0 {
+		cfg.Port = c.Port
+	} else if c.UseSSL {
+		cfg.Port = 636
+	} else if cfg.Port == 0 {
+		cfg.Port = 389
+	}
+	cfg.UseSSL = c.UseSSL
+	cfg.UseTLS = c.UseTLS && !c.UseSSL
+	cfg.SkipTLS = c.SkipTLSVerify
+	if c.Timeout > 0 {
+		cfg.Timeout = c.Timeout
+	} else if cfg.Timeout == 0 {
+		cfg.Timeout = 30
+	}
+	cfg.BindDN = c.BindDN
+	cfg.BindPassword = c.BindPassword
+	if c.BindPasswordEnv != "" {
+		cfg.BindPassword = os.Getenv(c.BindPasswordEnv)
+	}
+	cfg.BaseDN = c.BaseDN
+	cfg.UserBaseDN = c.UserBaseDN
+	cfg.SyncFilter = c.SyncFilter
+	cfg.Domain = c.Domain
+	cfg.GroupBaseDN = c.GroupBaseDN
+
+	override := func(dst *string, v string) {
+		if v != "" {
+			*dst = v
+		}
+	}
+	override(&cfg.UserFilter, c.UserFilter)
+	override(&cfg.GroupFilter, c.GroupFilter)
+	override(&cfg.GroupAttribute, c.GroupAttribute)
+	override(&cfg.LoginAttribute, c.Attributes.Login)
+	override(&cfg.EmailAttribute, c.Attributes.Email)
+	override(&cfg.FirstNameAttribute, c.Attributes.FirstName)
+	override(&cfg.LastNameAttribute, c.Attributes.LastName)
+	override(&cfg.CustomerIDAttribute, c.Attributes.CustomerID)
+
+	errs := ValidateConfig(cfg)
+	frontend := strings.ToLower(c.Frontend)
+	if frontend == "" {
+		frontend = FrontendAgent
+	}
+	if frontend != FrontendAgent && frontend != FrontendCustomer {
+		errs = append(errs, "frontend must be agent or customer")
+	}
+
+	d := &Directory{
+		Name:       name,
+		Frontend:   frontend,
+		Config:     cfg,
+		AutoCreate: c.AutoCreate,
+		Sync:       c.Sync,
+	}
+	for _, m := range c.GroupMappings {
+		if m.LDAPGroup == "" || m.Group == "" || m.Permission == "" {
+			errs = append(errs, "group mappings need ldap_group, group and permission")
+			continue
+		}
+		d.GroupMappings = append(d.GroupMappings, GroupMapping{LDAPGroup: m.LDAPGroup, Group: m.Group, Permission: m.Permission})
+	}
+	return d, errs
+}
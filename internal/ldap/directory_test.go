@@ -0,0 +1,196 @@
+package ldap
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/config"
+)
+
+// fakeServer is an in-memory directory answering the searches the
+// provider sends.
+type fakeServer struct {
+	users     map[string]map[string]string // DN -> attributes
+	passwords map[string]string            // DN -> password
+	groups    map[string][]string          // group name -> member DNs
+}
+
+type fakeConn struct {
+	srv *fakeServer
+}
+
+func (c *fakeConn) Bind(dn, password string) error {
+	if pw, ok := c.srv.passwords[dn]; ok && pw == password {
+		return nil
+	}
+	return ldap.NewError(ldap.LDAPResultInvalidCredentials, errors.New("invalid credentials"))
+}
+
+func (c *fakeConn) Search(req *ldap.SearchRequest) (*ldap.SearchResult, error) {
+	result := &ldap.SearchResult{}
+	if strings.Contains(req.Filter, "member=") {
+		for name, members := range c.srv.groups {
+			for _, m := range members {
+				if strings.Contains(req.Filter, m) {
+					result.Entries = append(result.Entries, ldap.NewEntry("cn="+name, map[string][]string{"cn": {name}}))
+				}
+			}
+		}
+		return result, nil
+	}
+	for dn, attrs := range c.srv.users {
+		if strings.Contains(req.Filter, "=*)") || strings.Contains(req.Filter, "="+attrs["uid"]+")") {
+			values := make(map[string][]string, len(attrs))
+			for k, v := range attrs {
+				values[k] = []string{v}
+			}
+			result.Entries = append(result.Entries, ldap.NewEntry(dn, values))
+		}
+	}
+	return result, nil
+}
+
+func (c *fakeConn) SearchWithPaging(req *ldap.SearchRequest, _ uint32) (*ldap.SearchResult, error) {
+	return c.Search(req)
+}
+
+func (c *fakeConn) Close() error { return nil }
+
+func newFakeServer() *fakeServer {
+	return &fakeServer{
+		users: map[string]map[string]string{
+			"uid=jdoe,ou=people,dc=example,dc=com": {
+				"uid": "jdoe", "mail": "jdoe@example.com", "givenName": "Jane", "sn": "Doe", "o": "ACME",
+			},
+		},
+		passwords: map[string]string{
+			"cn=svc,dc=example,dc=com":             "svc-secret",
+			"uid=jdoe,ou=people,dc=example,dc=com": "s3cret",
+		},
+		groups: map[string][]string{
+			"helpdesk": {"uid=jdoe,ou=people,dc=example,dc=com"},
+		},
+	}
+}
+
+func testDirectory(t *testing.T, name, frontend string, dial func(host string) (Conn, error)) *Directory {
+	t.Helper()
+	cfg := config.LDAPDirectoryConfig{
+		Name:          name,
+		Frontend:      frontend,
+		Type:          "openldap",
+		Host:          "ldap1.example.com",
+		FailoverHosts: []string{"ldap2.example.com"},
+		BindDN:        "cn=svc,dc=example,dc=com",
+		BindPassword:  "svc-secret",
+		BaseDN:        "dc=example,dc=com",
+		GroupBaseDN:   "ou=groups,dc=example,dc=com",
+	}
+	cfg.Attributes.CustomerID = "o"
+	dirs, problems := DirectoriesFromConfig([]config.LDAPDirectoryConfig{cfg})
+	require.Empty(t, problems)
+	require.Len(t, dirs, 1)
+	dirs[0].dial = dial
+	return dirs[0]
+}
+
+func TestDirectoryFailsOverToNextHost(t *testing.T) {
+	srv := newFakeServer()
+	var tried []string
+	dir := testDirectory(t, "corp", FrontendAgent, func(host string) (Conn, error) {
+		tried = append(tried, host)
+		if host == "ldap1.example.com" {
+			return nil, errors.New("connection refused")
+		}
+		return &fakeConn{srv: srv}, nil
+	})
+
+	user, err := dir.NewProvider().Verify("jdoe", "s3cret")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"ldap1.example.com", "ldap2.example.com"}, tried)
+	assert.Equal(t, "jdoe", user.Username)
+	assert.Equal(t, "jdoe@example.com", user.Email)
+	assert.Equal(t, "ACME", user.CustomerID)
+	assert.Equal(t, []string{"helpdesk"}, user.Groups)
+}
+
+func TestDirectoriesAuthenticate(t *testing.T) {
+	srv := newFakeServer()
+	empty := &fakeServer{passwords: srv.passwords}
+	down := testDirectory(t, "down", FrontendAgent, func(string) (Conn, error) {
+		return nil, errors.New("connection refused")
+	})
+	other := testDirectory(t, "other", FrontendAgent, func(string) (Conn, error) {
+		return &fakeConn{srv: empty}, nil
+	})
+	corp := testDirectory(t, "corp", FrontendAgent, func(string) (Conn, error) {
+		return &fakeConn{srv: srv}, nil
+	})
+	customers := testDirectory(t, "customers", FrontendCustomer, func(string) (Conn, error) {
+		return &fakeConn{srv: srv}, nil
+	})
+	dirs := Directories{down, other, corp, customers}
+
+	t.Run("skips unreachable and unknown", func(t *testing.T) {
+		dir, user, err := dirs.Authenticate(FrontendAgent, "jdoe", "s3cret")
+		require.NoError(t, err)
+		assert.Equal(t, "corp", dir.Name)
+		assert.Equal(t, "jdoe", user.Username)
+	})
+
+	t.Run("wrong password stops the search", func(t *testing.T) {
+		_, _, err := dirs.Authenticate(FrontendAgent, "jdoe", "wrong")
+		assert.ErrorIs(t, err, ErrInvalidCredentials)
+	})
+
+	t.Run("empty password is refused", func(t *testing.T) {
+		_, _, err := dirs.Authenticate(FrontendCustomer, "jdoe", "")
+		assert.ErrorIs(t, err, ErrInvalidCredentials)
+	})
+
+	t.Run("unknown user with a directory down", func(t *testing.T) {
+		_, _, err := dirs.Authenticate(FrontendAgent, "nobody", "s3cret")
+		assert.ErrorIs(t, err, ErrUnavailable)
+	})
+
+	t.Run("frontend is respected", func(t *testing.T) {
+		dir, _, err := dirs.Authenticate(FrontendCustomer, "jdoe", "s3cret")
+		require.NoError(t, err)
+		assert.Equal(t, "customers", dir.Name)
+	})
+}
+
+func TestProviderListUsers(t *testing.T) {
+	srv := newFakeServer()
+	dir := testDirectory(t, "corp", FrontendAgent, func(string) (Conn, error) {
+		return &fakeConn{srv: srv}, nil
+	})
+
+	users, err := dir.NewProvider().ListUsers()
+	require.NoError(t, err)
+	require.Len(t, users, 1)
+	assert.Equal(t, "jdoe", users[0].Username)
+	assert.Equal(t, []string{"helpdesk"}, users[0].Groups)
+}
+
+func TestDirectoriesFromConfig(t *testing.T) {
+	good := config.LDAPDirectoryConfig{Name: "ad", Type: "active_directory", Host: "dc1", BaseDN: "DC=example,DC=com"}
+	good.GroupMappings = []config.LDAPGroupMapping{{LDAPGroup: "Helpdesk", Group: "users", Permission: "rw"}}
+	badType := config.LDAPDirectoryConfig{Name: "bad", Type: "novell", Host: "x", BaseDN: "dc=x"}
+	badFrontend := config.LDAPDirectoryConfig{Type: "openldap", Frontend: "admin", Host: "x", BaseDN: "dc=x"}
+
+	dirs, problems := DirectoriesFromConfig([]config.LDAPDirectoryConfig{good, badType, badFrontend})
+	require.Len(t, dirs, 1)
+	assert.Equal(t, FrontendAgent, dirs[0].Frontend)
+	assert.Equal(t, "(sAMAccountName=%s)", dirs[0].Config.UserFilter)
+	assert.Equal(t, 389, dirs[0].Config.Port)
+	assert.Equal(t, []GroupMapping{{LDAPGroup: "Helpdesk", Group: "users", Permission: "rw"}}, dirs[0].GroupMappings)
+	require.Len(t, problems, 2)
+	assert.Contains(t, problems[0], "bad: unknown LDAP type")
+	assert.Contains(t, problems[1], "directory 3: frontend must be agent or customer")
+}
@@ -2,29 +2,49 @@ package ldap
 
 import (
 	"crypto/tls"
+	"errors"
 	"fmt"
 	"net"
+	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/go-ldap/ldap/v3"
 )
 
+// Errors returned by Provider.Verify.
+var (
+	ErrInvalidCredentials = errors.New("invalid LDAP credentials")
+	ErrUserNotFound       = errors.New("user not found in LDAP directory")
+	ErrUnavailable        = errors.New("no LDAP server could be reached")
+)
+
+// Conn is the part of an LDAP connection the provider uses.
+type Conn interface {
+	Bind(username, password string) error
+	Search(searchRequest *ldap.SearchRequest) (*ldap.SearchResult, error)
+	SearchWithPaging(searchRequest *ldap.SearchRequest, pagingSize uint32) (*ldap.SearchResult, error)
+	Close() error
+}
+
 // Provider implements LDAP/Active Directory authentication.
 type Provider struct {
 	config *Config
-	conn   *ldap.Conn
+	conn   Conn
+	dial   func(host string) (Conn, error)
 }
 
 // Config holds LDAP configuration.
 type Config struct {
 	// Connection settings
-	Host    string `json:"host"`
-	Port    int    `json:"port"`
-	UseSSL  bool   `json:"use_ssl"`
-	UseTLS  bool   `json:"use_tls"`
-	SkipTLS bool   `json:"skip_tls_verify"`
-	Timeout int    `json:"timeout_seconds"`
+	Host          string   `json:"host"`
+	FailoverHosts []string `json:"failover_hosts"` // Tried in order when Host is unreachable
+	Port          int      `json:"port"`
+	UseSSL        bool     `json:"use_ssl"`
+	UseTLS        bool     `json:"use_tls"`
+	SkipTLS       bool     `json:"skip_tls_verify"`
+	Timeout       int      `json:"timeout_seconds"`
 
 	// Bind settings
 	BindDN       string `json:"bind_dn"`
@@ -34,8 +54,11 @@ type Config struct {
 	BaseDN     string `json:"base_dn"`
 	UserFilter string `json:"user_filter"`  // e.g., "(uid=%s)" or "(sAMAccountName=%s)"
 	UserBaseDN string `json:"user_base_dn"` // Optional, defaults to BaseDN
+	SyncFilter string `json:"sync_filter"`  // Optional, defaults to UserFilter with a wildcard
 
 	// User attributes mapping
+	LoginAttribute       string `json:"login_attribute"`        // Optional, defaults to the attribute in UserFilter
+	CustomerIDAttribute  string `json:"customer_id_attribute"`  // e.g., "company"
 	EmailAttribute       string `json:"email_attribute"`        // e.g., "mail"
 	FirstNameAttribute   string `json:"first_name_attribute"`   // e.g., "givenName"
 	LastNameAttribute    string `json:"last_name_attribute"`    // e.g., "sn"
@@ -63,6 +86,7 @@ type User struct {
 	FirstName   string   `json:"first_name"`
 	LastName    string   `json:"last_name"`
 	DisplayName string   `json:"display_name"`
+	CustomerID  string   `json:"customer_id,omitempty"`
 	Groups      []string `json:"groups"`
 	Role        string   `json:"role"` // Admin, Agent, Customer
 }
@@ -122,57 +146,102 @@ var DefaultConfigs = map[string]*Config{
 
 // NewProvider creates a new LDAP provider.
 func NewProvider(config *Config) *Provider {
-	return &Provider{
+	p := &Provider{
 		config: config,
 	}
+	p.dial = p.dialHost
+	return p
+}
+
+// hosts returns Host followed by the failover hosts.
+func (p *Provider) hosts() []string {
+	hosts := make([]string, 0, 1+len(p.config.FailoverHosts))
+	for _, h := range append([]string{p.config.Host}, p.config.FailoverHosts...) {
+		if h = strings.TrimSpace(h); h != "" {
+			hosts = append(hosts, h)
+		}
+	}
+	return hosts
 }
 
-// Connect establishes connection to LDAP server.
+// Connect establishes connection to the first LDAP server that answers and
+// binds with the service account. A server that is down or refuses the
+// service bind for any reason other than bad credentials is skipped.
 func (p *Provider) Connect() error {
-	var err error
+	var lastErr error
+	for _, host := range p.hosts() {
+		conn, err := p.dial(host)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		// Bind with service account if provided
+		if p.config.BindDN != "" && p.config.BindPassword != "" {
+			if err := conn.Bind(p.config.BindDN, p.config.BindPassword); err != nil {
+				conn.Close()
+				if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+					return fmt.Errorf("failed to bind with service account: %w", err)
+				}
+				lastErr = err
+				continue
+			}
+		}
+
+		p.conn = conn
+		return nil
+	}
+	if lastErr == nil {
+		lastErr = errors.New("no LDAP host configured")
+	}
+	return fmt.Errorf("%w: %v", ErrUnavailable, lastErr)
+}
+
+// dialHost connects to one server. host may carry its own port.
+func (p *Provider) dialHost(host string) (Conn, error) {
+	port := p.config.Port
+	if h, portStr, err := net.SplitHostPort(host); err == nil {
+		if n, err := strconv.Atoi(portStr); err == nil {
+			host, port = h, n
+		}
+	}
 	// Prefer DialURL over raw Dial/DialTLS (deprecated)
 	scheme := "ldap"
 	if p.config.UseSSL {
 		scheme = "ldaps"
 	}
-	address := fmt.Sprintf("%s://%s:%d", scheme, p.config.Host, p.config.Port)
+	if port == 0 {
+		port = 389
+		if p.config.UseSSL {
+			port = 636
+		}
+	}
+	address := fmt.Sprintf("%s://%s", scheme, net.JoinHostPort(host, strconv.Itoa(port)))
 
-	// Establish connection
+	tlsConfig := &tls.Config{
+		ServerName:         host,
+		InsecureSkipVerify: p.config.SkipTLS,
+	}
 	dialer := &net.Dialer{Timeout: time.Duration(p.config.Timeout) * time.Second}
-	p.conn, err = ldap.DialURL(address, ldap.DialWithDialer(dialer))
-
+	conn, err := ldap.DialURL(address, ldap.DialWithDialer(dialer), ldap.DialWithTLSConfig(tlsConfig))
 	if err != nil {
-		return fmt.Errorf("failed to connect to LDAP server: %w", err)
+		return nil, fmt.Errorf("failed to connect to LDAP server %s: %w", address, err)
 	}
 
 	// Set timeout
 	if p.config.Timeout > 0 {
-		p.conn.SetTimeout(time.Duration(p.config.Timeout) * time.Second)
+		conn.SetTimeout(time.Duration(p.config.Timeout) * time.Second)
 	}
 
 	// Start TLS if requested
 	if p.config.UseTLS && !p.config.UseSSL {
-		tlsConfig := &tls.Config{
-			ServerName:         p.config.Host,
-			InsecureSkipVerify: p.config.SkipTLS,
-		}
-		err = p.conn.StartTLS(tlsConfig)
-		if err != nil {
-			p.conn.Close()
-			return fmt.Errorf("failed to start TLS: %w", err)
-		}
-	}
-
-	// Bind with service account if provided
-	if p.config.BindDN != "" && p.config.BindPassword != "" {
-		err = p.conn.Bind(p.config.BindDN, p.config.BindPassword)
-		if err != nil {
-			p.conn.Close()
-			return fmt.Errorf("failed to bind with service account: %w", err)
+		if err := conn.StartTLS(tlsConfig); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("failed to start TLS on %s: %w", address, err)
 		}
 	}
 
-	return nil
+	return conn, nil
 }
 
 // Close closes the LDAP connection.
@@ -185,12 +254,31 @@ func (p *Provider) Close() {
 
 // Authenticate authenticates a user with username and password.
 func (p *Provider) Authenticate(username, password string) *AuthResult {
+	user, err := p.Verify(username, password)
+	switch {
+	case errors.Is(err, ErrUserNotFound):
+		return &AuthResult{Success: false, ErrorMessage: "User not found"}
+	case errors.Is(err, ErrInvalidCredentials):
+		return &AuthResult{Success: false, ErrorMessage: "Invalid credentials"}
+	case err != nil:
+		return &AuthResult{Success: false, ErrorMessage: err.Error()}
+	}
+	return &AuthResult{
+		Success: true,
+		User:    user,
+	}
+}
+
+// Verify checks a password against the directory and returns the user with
+// their groups. Empty passwords are refused without asking the server, since
+// most directories treat them as an anonymous bind that always succeeds.
+func (p *Provider) Verify(username, password string) (*User, error) {
+	if username == "" || password == "" {
+		return nil, ErrInvalidCredentials
+	}
 	if p.conn == nil {
 		if err := p.Connect(); err != nil {
-			return &AuthResult{
-				Success:      false,
-				ErrorMessage: fmt.Sprintf("Connection failed: %v", err),
-			}
+			return nil, fmt.Errorf("connection failed: %w", err)
 		}
 		defer p.Close()
 	}
@@ -198,37 +286,22 @@ func (p *Provider) Authenticate(username, password string) *AuthResult {
 	// Search for user
 	user, err := p.findUser(username)
 	if err != nil {
-		return &AuthResult{
-			Success:      false,
-			ErrorMessage: fmt.Sprintf("User lookup failed: %v", err),
-		}
+		return nil, fmt.Errorf("user lookup failed: %w", err)
 	}
-
 	if user == nil {
-		return &AuthResult{
-			Success:      false,
-			ErrorMessage: "User not found",
-		}
+		return nil, ErrUserNotFound
 	}
 
 	// Try to bind as the user to verify password
 	err = p.conn.Bind(user.DN, password)
-	if err != nil {
-		// Re-bind with service account for subsequent operations
-		if p.config.BindDN != "" {
-			p.conn.Bind(p.config.BindDN, p.config.BindPassword)
-		}
 
-		return &AuthResult{
-			Success:      false,
-			ErrorMessage: "Invalid credentials",
-		}
-	}
-
-	// Re-bind with service account for group lookup
+	// Re-bind with service account for subsequent operations
 	if p.config.BindDN != "" {
 		p.conn.Bind(p.config.BindDN, p.config.BindPassword)
 	}
+	if err != nil {
+		return nil, ErrInvalidCredentials
+	}
 
 	// Get user groups and determine role
 	groups, err := p.getUserGroups(user.DN)
@@ -240,19 +313,101 @@ func (p *Provider) Authenticate(username, password string) *AuthResult {
 	user.Groups = groups
 	user.Role = p.determineRole(groups)
 
-	return &AuthResult{
-		Success: true,
-		User:    user,
+	return user, nil
+}
+
+// ListUsers returns all users matching SyncFilter (or the user filter with
+// a wildcard) together with their groups. The search is paged, so large
+// directories are not cut off by the server's size limit.
+func (p *Provider) ListUsers() ([]*User, error) {
+	if p.conn == nil {
+		if err := p.Connect(); err != nil {
+			return nil, fmt.Errorf("connection failed: %w", err)
+		}
+		defer p.Close()
+	}
+
+	filter := p.config.SyncFilter
+	if filter == "" {
+		filter = strings.ReplaceAll(p.config.UserFilter, "%s", "*")
+	}
+
+	searchRequest := ldap.NewSearchRequest(
+		p.userBaseDN(),
+		ldap.ScopeWholeSubtree,
+		ldap.NeverDerefAliases,
+		0, // No size limit
+		p.config.Timeout,
+		false,
+		filter,
+		p.userAttributes(),
+		nil,
+	)
+
+	result, err := p.conn.SearchWithPaging(searchRequest, 500)
+	if err != nil {
+		return nil, fmt.Errorf("search failed: %w", err)
+	}
+
+	users := make([]*User, 0, len(result.Entries))
+	for _, entry := range result.Entries {
+		user := p.userFromEntry(entry, "")
+		if user.Username == "" {
+			continue
+		}
+		groups, err := p.getUserGroups(user.DN)
+		if err != nil {
+			return nil, err
+		}
+		user.Groups = groups
+		user.Role = p.determineRole(groups)
+		users = append(users, user)
 	}
+
+	return users, nil
 }
 
-// findUser searches for a user in LDAP.
-func (p *Provider) findUser(username string) (*User, error) {
-	searchBaseDN := p.config.UserBaseDN
-	if searchBaseDN == "" {
-		searchBaseDN = p.config.BaseDN
+func (p *Provider) userBaseDN() string {
+	if p.config.UserBaseDN != "" {
+		return p.config.UserBaseDN
 	}
+	return p.config.BaseDN
+}
+
+// filterAttribute finds the attribute compared with %s in a user filter.
+var filterAttribute = regexp.MustCompile(`\(([A-Za-z][A-Za-z0-9-]*)=%s\)`)
+
+// loginAttribute returns the attribute that holds the GoatFlow login:
+// LoginAttribute, or else the attribute the user filter matches against.
+func (p *Provider) loginAttribute() string {
+	if p.config.LoginAttribute != "" {
+		return p.config.LoginAttribute
+	}
+	if m := filterAttribute.FindStringSubmatch(p.config.UserFilter); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+func (p *Provider) userAttributes() []string {
+	var attributes []string
+	for _, a := range []string{
+		p.loginAttribute(),
+		p.config.EmailAttribute,
+		p.config.FirstNameAttribute,
+		p.config.LastNameAttribute,
+		p.config.DisplayNameAttribute,
+		p.config.CustomerIDAttribute,
+	} {
+		if a != "" {
+			attributes = append(attributes, a)
+		}
+	}
+	return attributes
+}
 
+// findUser searches for a user in LDAP.
+func (p *Provider) findUser(username string) (*User, error) {
 	// Build search filter
 	filter := fmt.Sprintf(p.config.UserFilter, ldap.EscapeFilter(username))
 
@@ -265,23 +420,15 @@ func (p *Provider) findUser(username string) (*User, error) {
 		}
 	}
 
-	// Build attributes to retrieve
-	attributes := []string{
-		p.config.EmailAttribute,
-		p.config.FirstNameAttribute,
-		p.config.LastNameAttribute,
-		p.config.DisplayNameAttribute,
-	}
-
 	searchRequest := ldap.NewSearchRequest(
-		searchBaseDN,
+		p.userBaseDN(),
 		ldap.ScopeWholeSubtree,
 		ldap.NeverDerefAliases,
 		1, // Size limit - we only want one user
-		int(time.Duration(p.config.Timeout)*time.Second/time.Second),
+		p.config.Timeout,
 		false,
 		filter,
-		attributes,
+		p.userAttributes(),
 		nil,
 	)
 
@@ -294,9 +441,13 @@ func (p *Provider) findUser(username string) (*User, error) {
 		return nil, nil //nolint:nilnil // User not found
 	}
 
-	entry := result.Entries[0]
+	return p.userFromEntry(result.Entries[0], username), nil
+}
 
-	// Extract user information
+// userFromEntry maps a directory entry to a User. The login attribute wins
+// over the name the user signed in with, so every way of finding a user
+// leads to the same account.
+func (p *Provider) userFromEntry(entry *ldap.Entry, username string) *User {
 	user := &User{
 		DN:          entry.DN,
 		Username:    username,
@@ -305,6 +456,14 @@ func (p *Provider) findUser(username string) (*User, error) {
 		LastName:    entry.GetAttributeValue(p.config.LastNameAttribute),
 		DisplayName: entry.GetAttributeValue(p.config.DisplayNameAttribute),
 	}
+	if attr := p.loginAttribute(); attr != "" {
+		if login := entry.GetAttributeValue(attr); login != "" {
+			user.Username = login
+		}
+	}
+	if p.config.CustomerIDAttribute != "" {
+		user.CustomerID = entry.GetAttributeValue(p.config.CustomerIDAttribute)
+	}
 
 	// Use email as username if no explicit username
 	if user.Email != "" && user.Username == "" {
@@ -322,7 +481,7 @@ func (p *Provider) findUser(username string) (*User, error) {
 		}
 	}
 
-	return user, nil
+	return user
 }
 
 // getUserGroups retrieves groups for a user.
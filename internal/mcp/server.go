@@ -10,6 +10,7 @@ import (
 
 	"github.com/goatkit/goatflow/internal/database"
 	"github.com/goatkit/goatflow/internal/services"
+	"github.com/goatkit/goatflow/internal/storage"
 )
 
 const (
@@ -412,7 +413,7 @@ func (s *Server) toolGetTicket(ctx context.Context, args map[string]any) (*ToolC
 				"id":          artID,
 				"sender_type": senderType.String,
 				"subject":     subject.String,
-				"body":        body.String,
+				"body":        storage.DecodeText(body.String),
 				"create_time": artCreateTime.Time.Format("2006-01-02 15:04:05"),
 			})
 		}
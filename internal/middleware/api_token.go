@@ -80,9 +80,18 @@ func APITokenAuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		// Update last used asynchronously
+		clientIP := c.ClientIP()
+		if !apiToken.AllowsIP(clientIP) {
+			apierrors.Error(c, apierrors.CodeIPNotAllowed)
+			c.Abort()
+			return
+		}
+
+		// Update last used asynchronously; the gin context is reused once the
+		// request completes, so capture what the goroutine needs.
+		verifier := tokenVerifier
 		go func() {
-			_ = tokenVerifier.UpdateLastUsed(c.Request.Context(), apiToken.ID, c.ClientIP())
+			_ = verifier.UpdateLastUsed(context.Background(), apiToken.ID, clientIP)
 		}()
 
 		// Set user context
@@ -152,8 +161,19 @@ func authenticateAPIToken(c *gin.Context, token string) {
 	}
 	debugLog("DEBUG api_token: verified token id=%d user_id=%d", apiToken.ID, apiToken.UserID)
 
+	// ClientIP honours the configured trusted proxies, so forwarded headers
+	// from untrusted peers cannot spoof an allowed address.
+	clientIP := c.ClientIP()
+	if !apiToken.AllowsIP(clientIP) {
+		debugLog("DEBUG api_token: token id=%d not allowed from %s", apiToken.ID, clientIP)
+		apierrors.Error(c, apierrors.CodeIPNotAllowed)
+		c.Abort()
+		return
+	}
+
+	verifier := tokenVerifier
 	go func() {
-		_ = tokenVerifier.UpdateLastUsed(c.Request.Context(), apiToken.ID, c.ClientIP())
+		_ = verifier.UpdateLastUsed(context.Background(), apiToken.ID, clientIP)
 	}()
 
 	c.Set("user_id", apiToken.UserID)
@@ -235,6 +255,15 @@ func RequireScope(scope string) gin.HandlerFunc {
 	}
 }
 
+// BasicAuthChallenge asks clients that only speak Basic auth, such as
+// CalDAV clients, for credentials; the password is an API token.
+func BasicAuthChallenge(realm string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("WWW-Authenticate", `Basic realm="`+realm+`", charset="UTF-8"`)
+		c.Next()
+	}
+}
+
 // extractToken extracts token from Authorization header or cookies
 func extractToken(c *gin.Context) string {
 	authHeader := c.GetHeader("Authorization")
@@ -247,6 +276,11 @@ func extractToken(c *gin.Context) string {
 		if len(parts) == 1 && IsAPIToken(parts[0]) {
 			return parts[0]
 		}
+		// CalDAV and other clients that only speak Basic auth send an API
+		// token as the password; the user name is ignored
+		if _, password, ok := c.Request.BasicAuth(); ok && IsAPIToken(password) {
+			return password
+		}
 	}
 
 	if cookie, err := c.Cookie("auth_token"); err == nil && cookie != "" {
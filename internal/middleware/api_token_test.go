@@ -0,0 +1,102 @@
+package middleware
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/models"
+)
+
+type staticTokenVerifier struct {
+	token *models.APIToken
+}
+
+func (v *staticTokenVerifier) VerifyToken(ctx context.Context, rawToken string) (*models.APIToken, error) {
+	return v.token, nil
+}
+
+func (v *staticTokenVerifier) UpdateLastUsed(ctx context.Context, tokenID int64, ip string) error {
+	return nil
+}
+
+func TestAPITokenAuth_AllowedIPs(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	old := tokenVerifier
+	defer func() { tokenVerifier = old }()
+	SetAPITokenVerifier(&staticTokenVerifier{token: &models.APIToken{
+		ID:         7,
+		UserID:     1,
+		UserType:   models.APITokenUserAgent,
+		AllowedIPs: []string{"10.20.0.0/16"},
+	}})
+
+	router := gin.New()
+	require.NoError(t, router.SetTrustedProxies([]string{"192.168.1.1"}))
+	router.Use(UnifiedAuthMiddleware(nil))
+	router.GET("/ping", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	tests := []struct {
+		name      string
+		peer      string
+		forwarded string
+		want      int
+	}{
+		{"direct from allowed range", "10.20.1.5:4000", "", http.StatusOK},
+		{"direct from other network", "203.0.113.9:4000", "", http.StatusForbidden},
+		{"allowed client via trusted proxy", "192.168.1.1:4000", "10.20.9.9", http.StatusOK},
+		{"spoofed header from untrusted peer", "203.0.113.9:4000", "10.20.9.9", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/ping", nil)
+			req.RemoteAddr = tt.peer
+			req.Header.Set("Authorization", "Bearer gf_abcdefgh_secret")
+			if tt.forwarded != "" {
+				req.Header.Set("X-Forwarded-For", tt.forwarded)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.want, w.Code)
+			if tt.want == http.StatusForbidden {
+				assert.Contains(t, w.Body.String(), "ip_not_allowed")
+			}
+		})
+	}
+}
+
+func TestExtractToken(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name   string
+		header string
+		want   string
+	}{
+		{"bearer", "Bearer gf_abcdefgh_secret", "gf_abcdefgh_secret"},
+		{"raw api token", "gf_abcdefgh_secret", "gf_abcdefgh_secret"},
+		{"basic with api token password", "Basic " + base64.StdEncoding.EncodeToString([]byte("ann:gf_abcdefgh_secret")), "gf_abcdefgh_secret"},
+		{"basic with plain password", "Basic " + base64.StdEncoding.EncodeToString([]byte("ann:secret")), ""},
+		{"none", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodGet, "/caldav/", nil)
+			if tt.header != "" {
+				c.Request.Header.Set("Authorization", tt.header)
+			}
+			assert.Equal(t, tt.want, extractToken(c))
+		})
+	}
+}
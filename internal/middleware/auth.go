@@ -111,6 +111,10 @@ func (m *AuthMiddleware) RequireAuth() gin.HandlerFunc {
 		c.Set("tenant_host", c.Request.Host)
 		c.Set("claims", claims)
 
+		if !EnforceTwoFactor(c, claims) || !EnforcePasswordChange(c, claims) {
+			return
+		}
+
 		c.Next()
 	}
 }
@@ -195,6 +199,7 @@ func (m *AuthMiddleware) OptionalAuth() gin.HandlerFunc {
 		c.Set("tenant_host", c.Request.Host)
 		c.Set("claims", claims)
 		c.Set("authenticated", true)
+		c.Set("2fa_complete", claims.MFA)
 
 		c.Next()
 	}
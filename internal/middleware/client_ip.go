@@ -0,0 +1,23 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"github.com/goatkit/goatflow/internal/config"
+)
+
+// ConfigureClientIP applies server.trusted_proxies and server.remote_ip_headers
+// to the engine so c.ClientIP() only honours forwarding headers set by known
+// proxies. Token IP allow-lists and per-IP rate limits depend on this.
+// With no trusted proxies configured the socket peer address is used.
+func ConfigureClientIP(r *gin.Engine) error {
+	var proxies, headers []string
+	if cfg := config.Get(); cfg != nil {
+		proxies = cfg.Server.TrustedProxies
+		headers = cfg.Server.RemoteIPHeaders
+	}
+	if len(headers) > 0 {
+		r.RemoteIPHeaders = headers
+	}
+	return r.SetTrustedProxies(proxies)
+}
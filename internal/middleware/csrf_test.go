@@ -0,0 +1,116 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/config"
+)
+
+func newCSRFTestRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(csrfHandler(func() config.CSRFConfig {
+		return config.CSRFConfig{Enabled: true, ExemptPaths: []string{"/auth/sso/"}}
+	}))
+	router.GET("/form", func(c *gin.Context) {
+		c.String(http.StatusOK, CSRFToken(c))
+	})
+	ok := func(c *gin.Context) { c.Status(http.StatusNoContent) }
+	router.POST("/admin/save", ok)
+	router.DELETE("/admin/save", ok)
+	router.POST("/auth/sso/1/acs", ok)
+	return router
+}
+
+func TestCSRFToken_IssuesCookieOnce(t *testing.T) {
+	router := newCSRFTestRouter()
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/form", nil))
+	token := w.Body.String()
+	assert.Len(t, token, csrfTokenLength)
+	cookies := w.Result().Cookies()
+	require.Len(t, cookies, 1)
+	assert.Equal(t, CSRFCookieName, cookies[0].Name)
+	assert.Equal(t, token, cookies[0].Value)
+	assert.True(t, cookies[0].HttpOnly)
+
+	req := httptest.NewRequest(http.MethodGet, "/form", nil)
+	req.AddCookie(&http.Cookie{Name: CSRFCookieName, Value: token})
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, token, w.Body.String())
+	assert.Empty(t, w.Result().Cookies(), "a browser keeps its token")
+}
+
+func TestCSRF_ChecksSessionRequests(t *testing.T) {
+	router := newCSRFTestRouter()
+	token := newCSRFToken()
+
+	send := func(method, path string, body url.Values, setup func(*http.Request)) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.AddCookie(&http.Cookie{Name: "auth_token", Value: "session"})
+		req.AddCookie(&http.Cookie{Name: CSRFCookieName, Value: token})
+		if setup != nil {
+			setup(req)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := send(http.MethodPost, "/admin/save", nil, nil)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "core:csrf_invalid")
+
+	w = send(http.MethodPost, "/admin/save", url.Values{CSRFFormField: {newCSRFToken()}}, nil)
+	assert.Equal(t, http.StatusForbidden, w.Code, "another browser's token")
+
+	w = send(http.MethodPost, "/admin/save", url.Values{CSRFFormField: {token}}, nil)
+	assert.Equal(t, http.StatusNoContent, w.Code, "form field")
+
+	w = send(http.MethodDelete, "/admin/save", nil, func(r *http.Request) { r.Header.Set(CSRFHeader, token) })
+	assert.Equal(t, http.StatusNoContent, w.Code, "HTMX header")
+
+	w = send(http.MethodPost, "/admin/save", nil, func(r *http.Request) { r.Header.Set("Accept", "text/html") })
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "reload the page")
+
+	w = send(http.MethodPost, "/auth/sso/1/acs", nil, nil)
+	assert.Equal(t, http.StatusNoContent, w.Code, "exempt path")
+
+	w = send(http.MethodPost, "/admin/save", nil, func(r *http.Request) { r.Header.Set("Authorization", "Bearer gf_abc") })
+	assert.Equal(t, http.StatusNoContent, w.Code, "bearer token")
+
+	w = send(http.MethodPost, "/admin/save", nil, func(r *http.Request) { r.SetBasicAuth("cal", "gf_abc") })
+	assert.Equal(t, http.StatusForbidden, w.Code, "browsers resend basic credentials")
+}
+
+func TestCSRF_SkipsRequestsWithoutSession(t *testing.T) {
+	router := newCSRFTestRouter()
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/admin/save", nil))
+	assert.Equal(t, http.StatusNoContent, w.Code)
+}
+
+func TestCSRF_Disabled(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(csrfHandler(func() config.CSRFConfig { return config.CSRFConfig{} }))
+	router.POST("/admin/save", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/save", nil)
+	req.AddCookie(&http.Cookie{Name: "auth_token", Value: "session"})
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+}
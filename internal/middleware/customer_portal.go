@@ -10,6 +10,8 @@ import (
 
 	"github.com/goatkit/goatflow/internal/auth"
 	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/service"
 	"github.com/goatkit/goatflow/internal/sysconfig"
 )
 
@@ -24,7 +26,22 @@ func CustomerPortalGate(jwtManager *auth.JWTManager) gin.HandlerFunc {
 			return
 		}
 
-		cfg, err := sysconfig.LoadCustomerPortalConfig(db)
+		domains := service.NewPortalDomainService(db)
+		domain, err := domains.Resolve(c.Request.Context(), c.Request.Host)
+		if err != nil {
+			log.Printf("[CUST-PORTAL] portal domain lookup for %q failed: %v", c.Request.Host, err)
+		}
+		c.Set("portal_domain", domain)
+
+		var cfg sysconfig.CustomerPortalConfig
+		if domain != nil {
+			cfg, err = sysconfig.LoadCustomerPortalConfigForCompany(db, domain.CustomerID)
+			cfg.Domain = domain.Domain
+			cfg.Theme = domain.Theme
+			cfg.LogoURL = domain.LogoURL
+		} else {
+			cfg, err = sysconfig.LoadCustomerPortalConfig(db)
+		}
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load portal configuration"})
 			c.Abort()
@@ -63,6 +80,12 @@ func CustomerPortalGate(jwtManager *auth.JWTManager) gin.HandlerFunc {
 				c.Abort()
 				return
 			}
+			if !enforceCustomerSignInPolicy(c) {
+				return
+			}
+			if !enforcePortalDomainCompany(c, domains, domain) {
+				return
+			}
 			c.Next()
 			return
 		}
@@ -86,12 +109,54 @@ func CustomerPortalGate(jwtManager *auth.JWTManager) gin.HandlerFunc {
 				c.Abort()
 				return
 			}
+			if !enforceCustomerSignInPolicy(c) {
+				return
+			}
+			if !enforcePortalDomainCompany(c, domains, domain) {
+				return
+			}
 		}
 
 		c.Next()
 	}
 }
 
+// enforceCustomerSignInPolicy applies the two-factor policy and any
+// required password change to the customer that OptionalAuth put in the
+// context, if any.
+func enforceCustomerSignInPolicy(c *gin.Context) bool {
+	claims, _ := c.Get("claims")
+	cl, ok := claims.(*auth.Claims)
+	if !ok {
+		return true
+	}
+	return EnforceTwoFactor(c, cl) && EnforcePasswordChange(c, cl)
+}
+
+// enforcePortalDomainCompany stops signed-in customers of other companies
+// from using a customer company's portal domain.
+func enforcePortalDomainCompany(c *gin.Context, domains *service.PortalDomainService, domain *models.PortalDomain) bool {
+	username := c.GetString("username")
+	if domain == nil || username == "" {
+		return true
+	}
+	ok, err := domains.AllowsCustomer(c.Request.Context(), domain, username)
+	if err != nil {
+		log.Printf("[CUST-PORTAL] portal domain %s: customer check for %q failed: %v", domain.Domain, username, err)
+	}
+	if ok {
+		return true
+	}
+	if wantsHTML(c) {
+		c.String(http.StatusForbidden, "Your account cannot be used on this portal")
+		c.Abort()
+		return false
+	}
+	c.JSON(http.StatusForbidden, gin.H{"error": "customer does not belong to this portal"})
+	c.Abort()
+	return false
+}
+
 func respondPortalDisabled(c *gin.Context, cfg sysconfig.CustomerPortalConfig) {
 	accept := c.GetHeader("Accept")
 	if strings.Contains(accept, "text/html") {
@@ -0,0 +1,193 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/goatkit/goatflow/internal/apierrors"
+	"github.com/goatkit/goatflow/internal/config"
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/service"
+)
+
+const (
+	// IdempotencyKeyHeader is the request header carrying the client's key.
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayedHeader is set to "true" on replayed responses.
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+
+	maxIdempotencyKeyLength = 255
+	// Larger responses are not stored; the key is released instead.
+	maxIdempotentResponseSize = 1 << 20
+)
+
+// RequireIdempotency makes POST and PATCH requests carrying an
+// Idempotency-Key header safe to retry: the first response is stored and
+// replayed for retries with the same key from the same API token or user
+// until server.idempotency.ttl passes. Requests without the header run as
+// usual. Place it after the authentication and permission middleware so
+// rejected requests never reserve a key.
+func RequireIdempotency() gin.HandlerFunc {
+	return idempotencyHandler(func() (*service.IdempotencyService, error) {
+		cfg := config.Get()
+		if cfg != nil && !cfg.Server.Idempotency.Enabled {
+			return nil, nil
+		}
+		db, err := database.GetDB()
+		if err != nil || db == nil {
+			return nil, err
+		}
+		ttl := service.DefaultIdempotencyTTL
+		if cfg != nil {
+			ttl = cfg.Server.Idempotency.TTL
+		}
+		return service.NewIdempotencyService(db, ttl), nil
+	})
+}
+
+// idempotencyHandler builds the middleware around a service getter; a nil
+// service disables idempotency handling.
+func idempotencyHandler(getService func() (*service.IdempotencyService, error)) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" || (c.Request.Method != http.MethodPost && c.Request.Method != http.MethodPatch) {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength || strings.TrimSpace(key) != key {
+			apierrors.ErrorWithMessage(c, apierrors.CodeValidationFailed,
+				fmt.Sprintf("%s must be 1 to %d characters without surrounding spaces", IdempotencyKeyHeader, maxIdempotencyKeyLength))
+			c.Abort()
+			return
+		}
+		principal := idempotencyPrincipal(c)
+		if principal == "" {
+			c.Next()
+			return
+		}
+		svc, err := getService()
+		if err != nil || svc == nil {
+			c.Next()
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			apierrors.Error(c, apierrors.CodeInvalidRequest)
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		hash := sha256.New()
+		fmt.Fprintf(hash, "%s\n%s\n", c.Request.Method, c.Request.URL.Path)
+		hash.Write(body)
+
+		// Storing the outcome must not fail because the client went away.
+		ctx := context.WithoutCancel(c.Request.Context())
+		stored, err := svc.Begin(ctx, principal, key, c.Request.Method, c.Request.URL.Path, hex.EncodeToString(hash.Sum(nil)))
+		switch {
+		case errors.Is(err, service.ErrIdempotencyInProgress):
+			c.Header("Retry-After", "1")
+			apierrors.Error(c, apierrors.CodeIdempotencyInProgress)
+			c.Abort()
+			return
+		case errors.Is(err, service.ErrIdempotencyKeyReused):
+			apierrors.Error(c, apierrors.CodeIdempotencyKeyReused)
+			c.Abort()
+			return
+		case err != nil:
+			log.Printf("idempotency: reserving key for %s failed: %v", principal, err)
+			apierrors.Error(c, apierrors.CodeInternalError)
+			c.Abort()
+			return
+		case stored != nil:
+			c.Header(IdempotentReplayedHeader, "true")
+			contentType := stored.ContentType
+			if contentType == "" {
+				contentType = "application/json; charset=utf-8"
+			}
+			c.Data(stored.StatusCode, contentType, stored.Body)
+			c.Abort()
+			return
+		}
+
+		recorder := &idempotencyRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		completed := false
+		defer func() {
+			// Panics and server errors leave nothing behind, so the client
+			// can retry with the same key.
+			if !completed {
+				if err := svc.Release(ctx, principal, key); err != nil {
+					log.Printf("idempotency: %v", err)
+				}
+			}
+		}()
+
+		c.Next()
+
+		status := recorder.Status()
+		if status >= http.StatusInternalServerError || status == http.StatusTooManyRequests ||
+			recorder.body.Len() > maxIdempotentResponseSize {
+			return
+		}
+		if err := svc.Complete(ctx, principal, key, service.IdempotentResponse{
+			StatusCode:  status,
+			ContentType: recorder.Header().Get("Content-Type"),
+			Body:        recorder.body.Bytes(),
+		}); err != nil {
+			log.Printf("idempotency: %v", err)
+			return
+		}
+		completed = true
+	}
+}
+
+// idempotencyPrincipal returns who the key belongs to: the API token, or the
+// signed-in agent or customer.
+func idempotencyPrincipal(c *gin.Context) string {
+	if v, ok := c.Get("api_token"); ok {
+		if token, ok := v.(*models.APIToken); ok && token.ID > 0 {
+			return fmt.Sprintf("token:%d", token.ID)
+		}
+	}
+	userID := getQueueAccessUserIDFromCtxUint(c, 0)
+	if userID == 0 {
+		return ""
+	}
+	if c.GetBool("is_customer") || c.GetString("user_role") == "Customer" {
+		return fmt.Sprintf("customer:%d", userID)
+	}
+	return fmt.Sprintf("user:%d", userID)
+}
+
+// idempotencyRecorder copies the response body while writing it.
+type idempotencyRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *idempotencyRecorder) Write(b []byte) (int, error) {
+	if w.body.Len() <= maxIdempotentResponseSize {
+		w.body.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *idempotencyRecorder) WriteString(s string) (int, error) {
+	if w.body.Len() <= maxIdempotentResponseSize {
+		w.body.WriteString(s)
+	}
+	return w.ResponseWriter.WriteString(s)
+}
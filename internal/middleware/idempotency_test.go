@@ -0,0 +1,84 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/service"
+	"github.com/goatkit/goatflow/internal/testutil"
+)
+
+func newIdempotencyTestRouter(t *testing.T, status *int) (*gin.Engine, *int) {
+	t.Helper()
+	svc := service.NewIdempotencyService(testutil.MigratedDB(t), 0)
+
+	calls := 0
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("api_token", &models.APIToken{ID: 3})
+		c.Next()
+	})
+	router.POST("/tickets", idempotencyHandler(func() (*service.IdempotencyService, error) {
+		return svc, nil
+	}), func(c *gin.Context) {
+		calls++
+		c.JSON(*status, gin.H{"success": *status < 400, "call": calls})
+	})
+	return router, &calls
+}
+
+func sendIdempotent(router *gin.Engine, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/tickets", strings.NewReader(body))
+	if key != "" {
+		req.Header.Set(IdempotencyKeyHeader, key)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestIdempotency_ReplaysFirstResponse(t *testing.T) {
+	status := http.StatusCreated
+	router, calls := newIdempotencyTestRouter(t, &status)
+
+	first := sendIdempotent(router, "abc", `{"title":"Printer"}`)
+	assert.Equal(t, http.StatusCreated, first.Code)
+	assert.Empty(t, first.Header().Get(IdempotentReplayedHeader))
+
+	retry := sendIdempotent(router, "abc", `{"title":"Printer"}`)
+	assert.Equal(t, http.StatusCreated, retry.Code)
+	assert.Equal(t, "true", retry.Header().Get(IdempotentReplayedHeader))
+	assert.JSONEq(t, first.Body.String(), retry.Body.String())
+	assert.Equal(t, "application/json; charset=utf-8", retry.Header().Get("Content-Type"))
+	assert.Equal(t, 1, *calls, "the handler runs once")
+
+	reused := sendIdempotent(router, "abc", `{"title":"Scanner"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, reused.Code)
+	assert.Contains(t, reused.Body.String(), "core:idempotency_key_reused")
+
+	sendIdempotent(router, "", `{"title":"Printer"}`)
+	sendIdempotent(router, "", `{"title":"Printer"}`)
+	assert.Equal(t, 3, *calls, "requests without a key are never deduplicated")
+
+	invalid := sendIdempotent(router, strings.Repeat("k", 256), `{}`)
+	assert.Equal(t, http.StatusBadRequest, invalid.Code)
+}
+
+func TestIdempotency_ServerErrorsAreNotStored(t *testing.T) {
+	status := http.StatusInternalServerError
+	router, calls := newIdempotencyTestRouter(t, &status)
+
+	assert.Equal(t, http.StatusInternalServerError, sendIdempotent(router, "abc", `{}`).Code)
+
+	status = http.StatusCreated
+	retry := sendIdempotent(router, "abc", `{}`)
+	assert.Equal(t, http.StatusCreated, retry.Code)
+	assert.Empty(t, retry.Header().Get(IdempotentReplayedHeader))
+	assert.Equal(t, 2, *calls)
+}
@@ -0,0 +1,77 @@
+package middleware
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/goatkit/goatflow/internal/auth"
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/service"
+)
+
+// passwordChangeExemptPaths can be reached by an account that has to change
+// its password: the change forms and their submit routes, and logout.
+var passwordChangeExemptPaths = []string{
+	"/agent/password",
+	"/customer/password/",
+	"/logout",
+	"/customer/logout",
+	"/api/auth/logout",
+	"/api/languages",
+	"/api/themes",
+	"/static/",
+}
+
+// EnforcePasswordChange stops accounts that signed in with an expired
+// password from reaching anything but the change-password pages until they
+// change it. It returns false if the request was aborted. The requirement
+// is recorded at login (see PasswordPolicyService.RequireChange), so
+// directory sign-ins, whose passwords are not managed here, are never held.
+func EnforcePasswordChange(c *gin.Context, claims *auth.Claims) bool {
+	if claims == nil || claims.UserID == 0 || isPasswordChangeExempt(c.Request.URL.Path) {
+		return true
+	}
+	db, err := database.GetDB()
+	if err != nil || db == nil {
+		return true
+	}
+
+	kind, changeURL := service.PasswordAccountAgent, "/agent/password?expired=1"
+	if strings.EqualFold(claims.Role, "Customer") {
+		kind, changeURL = service.PasswordAccountCustomer, "/customer/password/form?expired=1"
+	}
+	required, err := service.NewPasswordPolicyService(db).ChangeRequired(c.Request.Context(), kind, int(claims.UserID))
+	if err != nil && !errors.Is(err, service.ErrPasswordAccountNotFound) {
+		log.Printf("Failed to check password change requirement for %s %d: %v", kind, claims.UserID, err)
+	}
+	if !required {
+		return true
+	}
+
+	if isAPIRequest(c) || c.GetHeader("HX-Request") == "true" {
+		c.JSON(http.StatusForbidden, gin.H{
+			"success":          false,
+			"error":            "Your password has expired and must be changed",
+			"password_expired": true,
+			"change_url":       changeURL,
+		})
+	} else {
+		c.Redirect(http.StatusFound, changeURL)
+	}
+	c.Abort()
+	return false
+}
+
+func isPasswordChangeExempt(path string) bool {
+	for _, exempt := range passwordChangeExemptPaths {
+		if path == exempt || (strings.HasSuffix(exempt, "/") && strings.HasPrefix(path, exempt)) ||
+			strings.HasPrefix(path, exempt+"/") {
+			return true
+		}
+	}
+	return false
+}
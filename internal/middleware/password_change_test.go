@@ -0,0 +1,95 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/auth"
+	"github.com/goatkit/goatflow/internal/service"
+	"github.com/goatkit/goatflow/internal/testutil"
+)
+
+func TestEnforcePasswordChange(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := testutil.UseMigratedDB(t)
+	for _, stmt := range []string{
+		`INSERT INTO users (id, login, pw, first_name, last_name, valid_id, create_time, create_by, change_time, change_by)
+			VALUES (10, 'agent1', 'x', 'Agent', 'One', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+		`INSERT INTO users (id, login, pw, first_name, last_name, valid_id, create_time, create_by, change_time, change_by)
+			VALUES (11, 'agent2', 'x', 'Agent', 'Two', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+		`INSERT INTO customer_user (id, login, email, customer_id, pw, first_name, last_name, valid_id,
+			create_time, create_by, change_time, change_by)
+			VALUES (20, 'cust', 'cust@example.com', 'ACME', 'x', 'Cust', 'Omer', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+	} {
+		_, err := db.Exec(stmt)
+		require.NoError(t, err)
+	}
+	policy := service.NewPasswordPolicyService(db)
+	ctx := context.Background()
+	require.NoError(t, policy.RequireChange(ctx, service.PasswordAccountAgent, "agent1"))
+	require.NoError(t, policy.RequireChange(ctx, service.PasswordAccountCustomer, "cust"))
+
+	jwtManager := auth.NewJWTManager("test-secret", time.Hour)
+	router := gin.New()
+	router.Use(NewAuthMiddleware(jwtManager).RequireAuth())
+	for _, path := range []string{"/dashboard", "/agent/password", "/api/tickets", "/logout",
+		"/customer/tickets", "/customer/password/form"} {
+		router.GET(path, func(c *gin.Context) { c.Status(http.StatusOK) })
+	}
+	router.POST("/agent/password/change", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	// The HTML login issues agent tokens without a login claim.
+	expired, err := jwtManager.GenerateToken(10, "agent1", "user", 1)
+	require.NoError(t, err)
+	current, err := jwtManager.GenerateToken(11, "agent2", "user", 1)
+	require.NoError(t, err)
+	customer, err := jwtManager.GenerateTokenWithLogin(20, "cust", "cust@example.com", "Customer", false, 1)
+	require.NoError(t, err)
+
+	do := func(method, path, token string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("pages redirect to the change form", func(t *testing.T) {
+		w := do(http.MethodGet, "/dashboard", expired)
+		assert.Equal(t, http.StatusFound, w.Code)
+		assert.Equal(t, "/agent/password?expired=1", w.Header().Get("Location"))
+
+		w = do(http.MethodGet, "/customer/tickets", customer)
+		assert.Equal(t, http.StatusFound, w.Code)
+		assert.Equal(t, "/customer/password/form?expired=1", w.Header().Get("Location"))
+	})
+
+	t.Run("API requests get 403", func(t *testing.T) {
+		w := do(http.MethodGet, "/api/tickets", expired)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Contains(t, w.Body.String(), `"password_expired":true`)
+	})
+
+	t.Run("change-password routes and logout stay reachable", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, do(http.MethodGet, "/agent/password", expired).Code)
+		assert.Equal(t, http.StatusOK, do(http.MethodPost, "/agent/password/change", expired).Code)
+		assert.Equal(t, http.StatusOK, do(http.MethodGet, "/logout", expired).Code)
+		assert.Equal(t, http.StatusOK, do(http.MethodGet, "/customer/password/form", customer).Code)
+	})
+
+	t.Run("other accounts pass", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, do(http.MethodGet, "/dashboard", current).Code)
+	})
+
+	t.Run("changing the password lifts the block", func(t *testing.T) {
+		require.NoError(t, policy.RecordChange(ctx, service.PasswordAccountAgent, "agent1", "newhash"))
+		assert.Equal(t, http.StatusOK, do(http.MethodGet, "/dashboard", expired).Code)
+	})
+}
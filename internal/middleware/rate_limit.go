@@ -40,17 +40,22 @@ func NewRateLimiter() *RateLimiter {
 
 // Allow checks if a request is allowed and consumes a token
 func (rl *RateLimiter) Allow(key string, limit int) bool {
+	return rl.AllowWindow(key, limit, time.Hour)
+}
+
+// AllowWindow is Allow for limit requests per window instead of per hour.
+// A key should always be used with the same window.
+func (rl *RateLimiter) AllowWindow(key string, limit int, window time.Duration) bool {
 	rl.mu.Lock()
 	defer rl.mu.Unlock()
 
 	b, exists := rl.buckets[key]
 	if !exists {
-		// Create new bucket with full tokens
-		// Default: limit requests per hour, refill at limit/3600 per second
+		// Create new bucket with full tokens, refilled at limit per window
 		b = &bucket{
 			tokens:     float64(limit),
 			limit:      float64(limit),
-			refillRate: float64(limit) / 3600.0, // per hour
+			refillRate: float64(limit) / window.Seconds(),
 			lastRefill: time.Now(),
 		}
 		rl.buckets[key] = b
@@ -99,25 +104,29 @@ func (rl *RateLimiter) cleanupLoop() {
 	}
 }
 
-// RateLimitMiddleware applies rate limiting based on API token or IP
-func RateLimitMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		var key string
-		var limit int
-
-		// Check if using API token (has custom rate limit)
-		if apiToken, exists := c.Get("api_token"); exists {
-			token := apiToken.(*models.APIToken)
-			key = "token:" + token.Prefix
-			limit = token.RateLimit
+// Archive searches get their own limiter so expensive scans never drain the
+// buckets used by regular requests.
+var archiveSearchRateLimiter = NewRateLimiter()
+
+// rateLimitKey returns the bucket key and hourly limit for the request,
+// preferring the API token's own limit over the IP default.
+func rateLimitKey(c *gin.Context) (string, int) {
+	if apiToken, exists := c.Get("api_token"); exists {
+		if token, ok := apiToken.(*models.APIToken); ok {
+			limit := token.RateLimit
 			if limit <= 0 {
 				limit = models.DefaultRateLimit
 			}
-		} else {
-			// Fall back to IP-based limiting
-			key = "ip:" + c.ClientIP()
-			limit = models.DefaultRateLimit
+			return "token:" + token.Prefix, limit
 		}
+	}
+	return "ip:" + c.ClientIP(), models.DefaultRateLimit
+}
+
+// RateLimitMiddleware applies rate limiting based on API token or IP
+func RateLimitMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key, limit := rateLimitKey(c)
 
 		if !globalRateLimiter.Allow(key, limit) {
 			remaining := globalRateLimiter.Remaining(key)
@@ -152,3 +161,18 @@ func RateLimitByIP(requestsPerHour int) gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// AllowArchiveSearch consumes a token from the caller's archive search bucket.
+// It sets the rate limit headers and returns false when the quota is exhausted.
+func AllowArchiveSearch(c *gin.Context, requestsPerHour int) bool {
+	key, _ := rateLimitKey(c)
+	if !archiveSearchRateLimiter.Allow(key, requestsPerHour) {
+		c.Header("X-RateLimit-Limit", strconv.Itoa(requestsPerHour))
+		c.Header("X-RateLimit-Remaining", "0")
+		c.Header("Retry-After", "60")
+		return false
+	}
+	c.Header("X-RateLimit-Limit", strconv.Itoa(requestsPerHour))
+	c.Header("X-RateLimit-Remaining", strconv.Itoa(archiveSearchRateLimiter.Remaining(key)))
+	return true
+}
@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
@@ -62,6 +63,19 @@ func TestRateLimiter_DifferentKeysHaveSeparateLimits(t *testing.T) {
 	assert.True(t, rl.Allow("key2", limit), "key2 should be allowed")
 }
 
+func TestRateLimiter_AllowWindowRefillsWithinWindow(t *testing.T) {
+	rl := NewRateLimiter()
+	key := "test:window"
+
+	assert.True(t, rl.AllowWindow(key, 2, 100*time.Millisecond))
+	assert.True(t, rl.AllowWindow(key, 2, 100*time.Millisecond))
+	assert.False(t, rl.AllowWindow(key, 2, 100*time.Millisecond), "third request in the window should be blocked")
+
+	// Half the window refills one token
+	time.Sleep(60 * time.Millisecond)
+	assert.True(t, rl.AllowWindow(key, 2, 100*time.Millisecond), "a refilled token should be allowed")
+}
+
 func TestRateLimiter_RemainingReturnsCorrectCount(t *testing.T) {
 	rl := NewRateLimiter()
 	key := "test:remaining"
@@ -235,3 +249,47 @@ func TestRateLimitMiddleware_DefaultsToDefaultRateLimitWhenTokenHasZero(t *testi
 	limitHeader := w.Header().Get("X-RateLimit-Limit")
 	assert.Equal(t, "1000", limitHeader, "should use default rate limit when token has 0")
 }
+
+func TestAllowArchiveSearch_SeparateFromGlobalLimiter(t *testing.T) {
+	oldLimiter := globalRateLimiter
+	oldArchive := archiveSearchRateLimiter
+	globalRateLimiter = NewRateLimiter()
+	archiveSearchRateLimiter = NewRateLimiter()
+	defer func() {
+		globalRateLimiter = oldLimiter
+		archiveSearchRateLimiter = oldArchive
+	}()
+
+	router := gin.New()
+	router.Use(RateLimitMiddleware())
+	router.POST("/search", func(c *gin.Context) {
+		if c.Query("archived") == "1" && !AllowArchiveSearch(c, 2) {
+			c.JSON(http.StatusTooManyRequests, gin.H{"success": false})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"success": true})
+	})
+
+	do := func(archived bool) *httptest.ResponseRecorder {
+		url := "/search"
+		if archived {
+			url += "?archived=1"
+		}
+		req, _ := http.NewRequest("POST", url, nil)
+		req.RemoteAddr = "10.0.0.9:12345"
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// Exhaust the archive quota
+	for i := 0; i < 2; i++ {
+		assert.Equal(t, http.StatusOK, do(true).Code, "archive request %d should succeed", i+1)
+	}
+	blocked := do(true)
+	assert.Equal(t, http.StatusTooManyRequests, blocked.Code, "archive search should be limited")
+	assert.Equal(t, "2", blocked.Header().Get("X-RateLimit-Limit"))
+
+	// Live searches are unaffected
+	assert.Equal(t, http.StatusOK, do(false).Code, "live search should not share the archive bucket")
+}
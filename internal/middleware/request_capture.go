@@ -0,0 +1,135 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/goatkit/goatflow/internal/apierrors"
+	"github.com/goatkit/goatflow/internal/config"
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/service"
+)
+
+// maxCapturedBodySize bounds the bodies buffered for a capture; requests
+// and responses above it are recorded without their body.
+const maxCapturedBodySize = 1 << 20
+
+// requestCaptureAdminPaths are never recorded: replay requests carry the
+// admin's token for the replay target.
+var requestCaptureAdminPaths = []string{"/api/v1/request-captures", "/api/v1/captured-requests"}
+
+// CaptureRequests records API requests matching an active request capture,
+// with secrets redacted, so they can be replayed later. Place it after the
+// authentication middleware; requests without a known caller are not
+// recorded.
+func CaptureRequests() gin.HandlerFunc {
+	return requestCaptureHandler(NewRequestCaptureService)
+}
+
+// NewRequestCaptureService creates a request capture service configured from
+// server.request_capture. It returns nil when request capture is disabled.
+func NewRequestCaptureService() (*service.RequestCaptureService, error) {
+	cfg := config.Get()
+	if cfg != nil && !cfg.Server.RequestCapture.Enabled {
+		return nil, nil
+	}
+	db, err := database.GetDB()
+	if err != nil || db == nil {
+		return nil, err
+	}
+	var opts service.RequestCaptureOptions
+	if cfg != nil {
+		rc := cfg.Server.RequestCapture
+		opts = service.RequestCaptureOptions{
+			MaxBodyBytes:  rc.MaxBodyBytes,
+			Retention:     rc.Retention,
+			RedactFields:  rc.RedactFields,
+			ReplayTargets: rc.ReplayTargets,
+			ReplayTimeout: rc.ReplayTimeout,
+		}
+	}
+	return service.NewRequestCaptureService(db, opts), nil
+}
+
+// requestCaptureHandler builds the middleware around a service getter; a nil
+// service disables capturing.
+func requestCaptureHandler(getService func() (*service.RequestCaptureService, error)) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		path := c.Request.URL.Path
+		if c.GetHeader(service.ReplayHeader) != "" {
+			c.Next()
+			return
+		}
+		for _, p := range requestCaptureAdminPaths {
+			if path == p || strings.HasPrefix(path, p+"/") {
+				c.Next()
+				return
+			}
+		}
+		principal := idempotencyPrincipal(c)
+		if principal == "" {
+			c.Next()
+			return
+		}
+		svc, err := getService()
+		if err != nil || svc == nil {
+			c.Next()
+			return
+		}
+		capture, err := svc.Match(c.Request.Context(), c.Request.Method, path, principal)
+		if err != nil {
+			log.Printf("request capture: matching %s %s failed: %v", c.Request.Method, path, err)
+		}
+		if capture == nil {
+			c.Next()
+			return
+		}
+
+		var reqBody []byte
+		omitted := c.Request.ContentLength < 0 || c.Request.ContentLength > maxCapturedBodySize
+		if !omitted && c.Request.ContentLength > 0 {
+			reqBody, err = io.ReadAll(c.Request.Body)
+			if err != nil {
+				apierrors.Error(c, apierrors.CodeInvalidRequest)
+				c.Abort()
+				return
+			}
+			c.Request.Body = io.NopCloser(bytes.NewReader(reqBody))
+		}
+		reqHeader := c.Request.Header.Clone()
+
+		recorder := &idempotencyRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		start := time.Now()
+
+		c.Next()
+
+		respBody := recorder.body.Bytes()
+		if recorder.body.Len() > maxCapturedBodySize {
+			respBody, omitted = nil, true
+		}
+		// Recording must not fail because the client went away.
+		ctx := context.WithoutCancel(c.Request.Context())
+		if err := svc.Record(ctx, capture, service.CapturedExchange{
+			Principal:      principal,
+			Method:         c.Request.Method,
+			Path:           path,
+			Query:          c.Request.URL.RawQuery,
+			RequestHeader:  reqHeader,
+			RequestBody:    reqBody,
+			ResponseHeader: recorder.Header().Clone(),
+			StatusCode:     recorder.Status(),
+			ResponseBody:   respBody,
+			BodyOmitted:    omitted,
+			Duration:       time.Since(start),
+		}); err != nil {
+			log.Printf("request capture %d: %v", capture.ID, err)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/service"
+	"github.com/goatkit/goatflow/internal/testutil"
+)
+
+func TestCaptureRequests_RecordsMatchingRequests(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db := testutil.MigratedDB(t)
+	_, err := db.Exec(`INSERT INTO users (id, login, pw, first_name, last_name, valid_id, create_time, create_by, change_time, change_by)
+		VALUES (1, 'root@localhost', 'x', 'Admin', 'OTRS', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`)
+	require.NoError(t, err)
+	svc := service.NewRequestCaptureService(db, service.RequestCaptureOptions{})
+	ctx := context.Background()
+	capture, err := svc.CreateCapture(ctx, &models.RequestCapture{Name: "token 3", Principal: "token:3"}, 1)
+	require.NoError(t, err)
+	// Other tests share the package-level cache of active captures.
+	t.Cleanup(func() { _ = svc.DeleteCapture(ctx, capture.ID) })
+
+	router := gin.New()
+	router.Use(func(c *gin.Context) {
+		c.Set("api_token", &models.APIToken{ID: 3})
+		c.Next()
+	})
+	router.Use(requestCaptureHandler(func() (*service.RequestCaptureService, error) { return svc, nil }))
+	router.POST("/api/v1/tickets", func(c *gin.Context) {
+		var body map[string]interface{}
+		_ = c.ShouldBindJSON(&body)
+		c.JSON(http.StatusCreated, gin.H{"success": true, "title": body["title"]})
+	})
+	router.POST("/api/v1/captured-requests/:id/replay", func(c *gin.Context) {
+		c.Status(http.StatusCreated)
+	})
+
+	send := func(path, body string, header ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := send("/api/v1/tickets", `{"title":"Printer","password":"x"}`)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.JSONEq(t, `{"success":true,"title":"Printer"}`, w.Body.String(), "the handler still sees the body")
+
+	send("/api/v1/tickets", `{"title":"again"}`, service.ReplayHeader, "1")
+	send("/api/v1/captured-requests/1/replay", `{"target":"staging","token":"gf_x"}`)
+
+	requests, err := svc.ListRequests(ctx, capture.ID)
+	require.NoError(t, err)
+	require.Len(t, requests, 1, "replays and the replay API are not recorded")
+	r := requests[0]
+	assert.Equal(t, "token:3", r.Principal)
+	assert.Equal(t, "/api/v1/tickets", r.Path)
+	assert.Equal(t, http.StatusCreated, r.StatusCode)
+	assert.JSONEq(t, `{"title":"Printer","password":"[REDACTED]"}`, r.RequestBody)
+	assert.JSONEq(t, `{"success":true,"title":"Printer"}`, r.ResponseBody)
+}
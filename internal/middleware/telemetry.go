@@ -0,0 +1,23 @@
+package middleware
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/goatkit/goatflow/internal/telemetry"
+)
+
+// Telemetry counts each request against its route template for the
+// opt-in usage telemetry. It does nothing while telemetry is off.
+func Telemetry() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !telemetry.Enabled() {
+			c.Next()
+			return
+		}
+		start := time.Now()
+		c.Next()
+		telemetry.RecordRoute(c.Request.Method, c.FullPath(), c.Writer.Status(), time.Since(start))
+	}
+}
@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/service"
+)
+
+// RequireTicketUnlockedForUser rejects the request with 409 when another
+// agent holds a live lock on the ticket, so two agents cannot reply to the
+// same ticket at once. It expects a ticket_access_* middleware to have run,
+// which resolves the ticket ID from a ticket number.
+func RequireTicketUnlockedForUser() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID := int(getQueueAccessUserIDFromCtxUint(c, 0))
+		ticketID := 0
+		if v, ok := c.Get("ticket_id"); ok {
+			if id, ok := v.(uint64); ok {
+				ticketID = int(id)
+			}
+		}
+		if ticketID == 0 {
+			ticketID, _ = strconv.Atoi(c.Param("id"))
+		}
+		if userID == 0 || ticketID == 0 {
+			c.Next()
+			return
+		}
+
+		db, err := database.GetDB()
+		if err != nil || db == nil {
+			c.Next()
+			return
+		}
+
+		err = service.NewTicketLockService(db, 0).CheckReply(c.Request.Context(), ticketID, userID)
+		switch {
+		case err == nil:
+			c.Next()
+		case errors.Is(err, service.ErrTicketLockedByOther):
+			c.JSON(http.StatusConflict, gin.H{"success": false, "error": "Ticket is locked by another agent"})
+			c.Abort()
+		default:
+			log.Printf("ticket lock check for ticket %d failed: %v", ticketID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check ticket lock"})
+			c.Abort()
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/goatkit/goatflow/internal/tracing"
+)
+
+// Tracing starts a server span for every request, continuing the caller's
+// trace when the request has a traceparent header. The span is named after
+// the matched route, and the request context carries it, so work started
+// by later handlers joins the trace. Sampled requests get an X-Trace-Id
+// response header for finding the trace in the backend.
+func Tracing() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !tracing.Enabled() {
+			c.Next()
+			return
+		}
+		req := c.Request
+		route := c.FullPath()
+		name := req.Method
+		if route != "" {
+			name += " " + route
+		}
+		ctx, span := tracing.StartServer(tracing.Extract(req.Context(), req.Header), name,
+			tracing.String("http.request.method", req.Method),
+			tracing.String("http.route", route),
+			tracing.String("url.path", req.URL.Path),
+			tracing.String("client.address", c.ClientIP()),
+			tracing.String("user_agent.original", req.UserAgent()),
+		)
+		defer span.End()
+		if span.IsRecording() {
+			c.Header("X-Trace-Id", span.SpanContext().TraceID.String())
+		}
+		c.Request = req.WithContext(ctx)
+
+		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttributes(tracing.Int("http.response.status_code", status))
+		if userID, ok := c.Get("user_id"); ok {
+			span.SetAttributes(tracing.String("enduser.id", fmt.Sprint(userID)))
+		}
+		if len(c.Errors) > 0 {
+			span.SetAttributes(tracing.String("gin.errors", c.Errors.String()))
+		}
+		if status >= http.StatusInternalServerError {
+			span.SetError(http.StatusText(status))
+		}
+	}
+}
+
+// TraceHandler wraps a route middleware or handler in a span named name,
+// so a trace shows where the middleware chain spent its time. A middleware
+// that calls c.Next() has the rest of the chain as children. It only
+// records within a traced request.
+func TraceHandler(name string, h gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request == nil {
+			h(c)
+			return
+		}
+		parent := c.Request.Context()
+		ctx, span := tracing.StartChild(parent, tracing.SpanKindInternal, name)
+		if span == nil {
+			h(c)
+			return
+		}
+		c.Request = c.Request.WithContext(ctx)
+		h(c)
+		span.End()
+		// Handlers after one that does not call c.Next() are its siblings.
+		if c.Request.Context() == ctx {
+			c.Request = c.Request.WithContext(parent)
+		}
+	}
+}
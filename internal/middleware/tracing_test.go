@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/tracing"
+)
+
+func TestTracing_ContinuesIncomingTrace(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tracing.SetTracer(tracing.NewTracer(0, nil))
+	t.Cleanup(func() { tracing.SetTracer(nil) })
+
+	var handlerSpan, authSpan tracing.SpanContext
+	router := gin.New()
+	router.Use(Tracing())
+	auth := TraceHandler("middleware auth", func(c *gin.Context) {
+		authSpan = tracing.SpanContextFromContext(c.Request.Context())
+		c.Set("user_id", 7)
+		c.Next()
+	})
+	handler := TraceHandler("handler ticket", func(c *gin.Context) {
+		handlerSpan = tracing.SpanContextFromContext(c.Request.Context())
+		c.Status(http.StatusNoContent)
+	})
+	router.GET("/tickets/:id", auth, handler)
+
+	const incoming = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	req := httptest.NewRequest(http.MethodGet, "/tickets/5", nil)
+	req.Header.Set(tracing.TraceParentHeader, incoming)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", w.Header().Get("X-Trace-Id"))
+	require.True(t, handlerSpan.IsValid())
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", handlerSpan.TraceID.String())
+	assert.NotEqual(t, authSpan.SpanID, handlerSpan.SpanID)
+}
+
+func TestTracing_UnsampledRequest(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tracing.SetTracer(tracing.NewTracer(0, nil))
+	t.Cleanup(func() { tracing.SetTracer(nil) })
+
+	called := false
+	router := gin.New()
+	router.Use(Tracing())
+	router.GET("/", TraceHandler("handler root", func(c *gin.Context) {
+		called = true
+		c.Status(http.StatusOK)
+	}))
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	assert.True(t, called)
+	assert.Empty(t, w.Header().Get("X-Trace-Id"))
+}
@@ -0,0 +1,100 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/goatkit/goatflow/internal/auth"
+	"github.com/goatkit/goatflow/internal/config"
+)
+
+// twoFactorExemptPaths can be reached by a session that still owes its second
+// factor, so the user can enrol (profile page and preference APIs), change an
+// expired password first (see EnforcePasswordChange) or leave.
+var twoFactorExemptPaths = []string{
+	"/profile",
+	"/agent/password",
+	"/customer/password/",
+	"/api/profile",
+	"/api/preferences/",
+	"/customer/profile",
+	"/customer/api/preferences/",
+	"/logout",
+	"/customer/logout",
+	"/api/auth/logout",
+	"/api/languages",
+	"/api/themes",
+	"/static/",
+}
+
+// TwoFactorPolicy returns the per-role two-factor policy from configuration.
+func TwoFactorPolicy() auth.TwoFactorPolicy {
+	if cfg := config.Get(); cfg != nil {
+		return auth.ParseTwoFactorPolicy(cfg.Auth.TwoFactor.RequiredRoles)
+	}
+	return auth.TwoFactorPolicy{}
+}
+
+// EnforceTwoFactor marks the request context with "2fa_complete" from the
+// token's mfa claim and, when the policy requires a second factor for the
+// caller's role, stops sessions that have not completed one. It returns
+// false if the request was aborted. The role is taken from "user_role" when
+// an earlier middleware resolved it from group membership.
+func EnforceTwoFactor(c *gin.Context, claims *auth.Claims) bool {
+	if claims == nil {
+		return true
+	}
+	role := c.GetString("user_role")
+	if role == "" {
+		role = claims.Role
+	}
+	return enforceTwoFactor(c, claims, role, claims.IsAdmin)
+}
+
+// RequireAdminTwoFactor applies the admin part of the policy once the
+// handler chain has established that the caller is an admin. Agent logins
+// do not always carry the admin flag in their token, so the admin check
+// cannot rely on the claims alone.
+func RequireAdminTwoFactor(c *gin.Context) bool {
+	claims, _ := c.Get("claims")
+	if cl, ok := claims.(*auth.Claims); ok {
+		return enforceTwoFactor(c, cl, "Admin", true)
+	}
+	return true
+}
+
+func enforceTwoFactor(c *gin.Context, claims *auth.Claims, role string, isAdmin bool) bool {
+	c.Set("2fa_complete", claims.MFA)
+	if claims.MFA || !TwoFactorPolicy().Requires(role, isAdmin) || isTwoFactorExempt(c.Request.URL.Path) {
+		return true
+	}
+
+	setupURL := "/profile#2fa-section"
+	if strings.EqualFold(claims.Role, "Customer") {
+		setupURL = "/customer/profile#2fa-section"
+	}
+	if isAPIRequest(c) || c.GetHeader("HX-Request") == "true" {
+		c.JSON(http.StatusForbidden, gin.H{
+			"success":             false,
+			"error":               "Two-factor authentication is required for your account",
+			"two_factor_required": true,
+			"setup_url":           setupURL,
+		})
+	} else {
+		c.Redirect(http.StatusFound, setupURL)
+	}
+	c.Abort()
+	return false
+}
+
+func isTwoFactorExempt(path string) bool {
+	for _, exempt := range twoFactorExemptPaths {
+		if path == exempt || (strings.HasSuffix(exempt, "/") && strings.HasPrefix(path, exempt)) ||
+			strings.HasPrefix(path, exempt+"/") {
+			return true
+		}
+	}
+	return false
+}
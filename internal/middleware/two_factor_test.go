@@ -0,0 +1,109 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/auth"
+	"github.com/goatkit/goatflow/internal/config"
+)
+
+func setTwoFactorPolicy(t *testing.T, roles string) {
+	t.Helper()
+	write := func(body string) {
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		require.NoError(t, os.WriteFile(path, []byte(body), 0o600))
+		require.NoError(t, config.LoadFromFile(path))
+	}
+	write("auth:\n  two_factor:\n    required_roles: [" + roles + "]\n")
+	t.Cleanup(func() { write("auth:\n  two_factor:\n    required_roles: []\n") })
+}
+
+func TestEnforceTwoFactor(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	setTwoFactorPolicy(t, "agent")
+
+	jwtManager := auth.NewJWTManager("test-secret", time.Hour)
+	router := gin.New()
+	router.Use(NewAuthMiddleware(jwtManager).RequireAuth())
+	for _, path := range []string{"/dashboard", "/profile", "/api/tickets", "/api/preferences/2fa/status"} {
+		router.GET(path, func(c *gin.Context) {
+			c.JSON(http.StatusOK, gin.H{"2fa_complete": c.GetBool("2fa_complete")})
+		})
+	}
+
+	plain, err := jwtManager.GenerateTokenWithLogin(1, "agent1", "agent1@example.com", "Agent", false, 1)
+	require.NoError(t, err)
+	verified, err := jwtManager.GenerateTwoFactorToken(1, "agent1", "agent1@example.com", "Agent", false, 1)
+	require.NoError(t, err)
+	customer, err := jwtManager.GenerateTokenWithLogin(2, "cust", "cust@example.com", "Customer", false, 1)
+	require.NoError(t, err)
+
+	get := func(path, token string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("pages redirect to enrolment", func(t *testing.T) {
+		w := get("/dashboard", plain)
+		assert.Equal(t, http.StatusFound, w.Code)
+		assert.Equal(t, "/profile#2fa-section", w.Header().Get("Location"))
+	})
+
+	t.Run("API requests get 403", func(t *testing.T) {
+		w := get("/api/tickets", plain)
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Contains(t, w.Body.String(), `"two_factor_required":true`)
+	})
+
+	t.Run("enrolment paths stay reachable", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, get("/profile", plain).Code)
+		assert.Equal(t, http.StatusOK, get("/api/preferences/2fa/status", plain).Code)
+	})
+
+	t.Run("mfa claim passes", func(t *testing.T) {
+		w := get("/dashboard", verified)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"2fa_complete":true`)
+	})
+
+	t.Run("roles outside the policy pass", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, get("/dashboard", customer).Code)
+	})
+}
+
+func TestRequireAdminTwoFactor(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	setTwoFactorPolicy(t, "admin")
+
+	run := func(claims *auth.Claims) int {
+		router := gin.New()
+		router.GET("/admin", func(c *gin.Context) {
+			c.Set("claims", claims)
+			if !EnforceTwoFactor(c, claims) || !RequireAdminTwoFactor(c) {
+				return
+			}
+			c.Status(http.StatusOK)
+		})
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin", nil))
+		return w.Code
+	}
+
+	// Agent-level routes are open, admin routes need the second factor.
+	assert.Equal(t, http.StatusFound, run(&auth.Claims{UserID: 1, Role: "Agent"}))
+	assert.Equal(t, http.StatusOK, run(&auth.Claims{UserID: 1, Role: "Agent", MFA: true}))
+	assert.True(t, TwoFactorPolicy().Requires(auth.TwoFactorRoleAdmin, true))
+	assert.False(t, TwoFactorPolicy().Requires(auth.TwoFactorRoleAgent, false))
+}
@@ -0,0 +1,78 @@
+package models
+
+import "time"
+
+// AgentStatsGrouping splits agent statistics further.
+type AgentStatsGrouping string
+
+// Agent statistics groupings.
+const (
+	AgentStatsByAgent AgentStatsGrouping = ""
+	AgentStatsByQueue AgentStatsGrouping = "queue"
+	// AgentStatsByTeam groups by the agent group that owns the ticket's queue.
+	AgentStatsByTeam AgentStatsGrouping = "team"
+)
+
+// IsValid reports whether g is a supported grouping.
+func (g AgentStatsGrouping) IsValid() bool {
+	switch g {
+	case AgentStatsByAgent, AgentStatsByQueue, AgentStatsByTeam:
+		return true
+	}
+	return false
+}
+
+// AgentResolution is a ticket closed by an agent: the ticket's owner when
+// it moved into a closed state.
+type AgentResolution struct {
+	TicketID int64
+	AgentID  int
+	QueueID  int
+	Time     time.Time
+	// Reopened tells whether the ticket left its closed state afterwards.
+	Reopened bool
+	// HasSLA tells whether the ticket has a solution time target, and
+	// SLAMet whether it was closed before its solution time escalated.
+	HasSLA bool
+	SLAMet bool
+}
+
+// AgentTimeEntry is the time an agent accounted on a ticket.
+type AgentTimeEntry struct {
+	TicketID int64
+	AgentID  int
+	QueueID  int
+	Minutes  float64
+}
+
+// AgentStats holds the performance figures of one agent, or of one agent in
+// one queue or team.
+type AgentStats struct {
+	AgentID    int    `json:"agent_id"`
+	AgentLogin string `json:"agent_login"`
+	AgentName  string `json:"agent_name"`
+	QueueID    *int   `json:"queue_id,omitempty"`
+	QueueName  string `json:"queue_name,omitempty"`
+	TeamID     *int   `json:"team_id,omitempty"`
+	TeamName   string `json:"team_name,omitempty"`
+	Resolved   int    `json:"resolved"`
+	Reopened   int    `json:"reopened"`
+	// ReopenedRatio is Reopened / Resolved.
+	ReopenedRatio  *float64 `json:"reopened_ratio"`
+	HandledTickets int      `json:"handled_tickets"`
+	// AvgHandleTimeMinutes is the accounted time per handled ticket.
+	AvgHandleTimeMinutes *float64 `json:"avg_handle_time_minutes"`
+	SLATickets           int      `json:"sla_tickets"`
+	SLAMet               int      `json:"sla_met"`
+	// SLACompliance is the percentage of SLATickets closed in time.
+	SLACompliance *float64 `json:"sla_compliance"`
+}
+
+// AgentStatsReport is the answer to an agent statistics request.
+type AgentStatsReport struct {
+	Window  string             `json:"window"`
+	From    string             `json:"from"`
+	To      string             `json:"to"`
+	GroupBy AgentStatsGrouping `json:"group_by,omitempty"`
+	Agents  []AgentStats       `json:"agents"`
+}
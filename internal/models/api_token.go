@@ -2,6 +2,9 @@ package models
 
 import (
 	"database/sql"
+	"fmt"
+	"net"
+	"strings"
 	"time"
 )
 
@@ -15,23 +18,25 @@ const (
 
 // APIToken represents a personal access token for API authentication
 type APIToken struct {
-	ID            int64            `json:"id" db:"id"`
-	UserID        int              `json:"user_id" db:"user_id"`
-	UserType      APITokenUserType `json:"user_type" db:"user_type"`
-	Name          string           `json:"name" db:"name"`
-	Prefix        string           `json:"prefix" db:"prefix"`
-	TokenHash     string           `json:"-" db:"token_hash"` // Never expose hash
-	Scopes        []string         `json:"scopes,omitempty"`  // Parsed from JSON
-	ScopesJSON    sql.NullString   `json:"-" db:"scopes"`     // Raw JSON from DB
-	ExpiresAt     sql.NullTime     `json:"expires_at,omitempty" db:"expires_at"`
-	LastUsedAt    sql.NullTime     `json:"last_used_at,omitempty" db:"last_used_at"`
-	LastUsedIP    sql.NullString   `json:"last_used_ip,omitempty" db:"last_used_ip"`
-	RateLimit     int              `json:"rate_limit" db:"rate_limit"`
-	CreatedAt     time.Time        `json:"created_at" db:"created_at"`
-	CreatedBy     sql.NullInt64    `json:"created_by,omitempty" db:"created_by"`
-	RevokedAt     sql.NullTime     `json:"revoked_at,omitempty" db:"revoked_at"`
-	RevokedBy     sql.NullInt64    `json:"revoked_by,omitempty" db:"revoked_by"`
-	CustomerLogin string           `json:"customer_login,omitempty"` // For customer tokens: login from customer_user
+	ID             int64            `json:"id" db:"id"`
+	UserID         int              `json:"user_id" db:"user_id"`
+	UserType       APITokenUserType `json:"user_type" db:"user_type"`
+	Name           string           `json:"name" db:"name"`
+	Prefix         string           `json:"prefix" db:"prefix"`
+	TokenHash      string           `json:"-" db:"token_hash"` // Never expose hash
+	Scopes         []string         `json:"scopes,omitempty"`  // Parsed from JSON
+	ScopesJSON     sql.NullString   `json:"-" db:"scopes"`     // Raw JSON from DB
+	ExpiresAt      sql.NullTime     `json:"expires_at,omitempty" db:"expires_at"`
+	LastUsedAt     sql.NullTime     `json:"last_used_at,omitempty" db:"last_used_at"`
+	LastUsedIP     sql.NullString   `json:"last_used_ip,omitempty" db:"last_used_ip"`
+	RateLimit      int              `json:"rate_limit" db:"rate_limit"`
+	AllowedIPs     []string         `json:"allowed_ips,omitempty"` // CIDR allow-list, parsed from JSON
+	AllowedIPsJSON sql.NullString   `json:"-" db:"allowed_ips"`    // Raw JSON from DB
+	CreatedAt      time.Time        `json:"created_at" db:"created_at"`
+	CreatedBy      sql.NullInt64    `json:"created_by,omitempty" db:"created_by"`
+	RevokedAt      sql.NullTime     `json:"revoked_at,omitempty" db:"revoked_at"`
+	RevokedBy      sql.NullInt64    `json:"revoked_by,omitempty" db:"revoked_by"`
+	CustomerLogin  string           `json:"customer_login,omitempty"` // For customer tokens: login from customer_user
 }
 
 // IsExpired returns true if the token has expired
@@ -52,6 +57,56 @@ func (t *APIToken) IsActive() bool {
 	return !t.IsRevoked() && !t.IsExpired()
 }
 
+// AllowsIP returns true if the client IP is inside one of the token's CIDR
+// ranges. Tokens without an allow-list may be used from anywhere.
+func (t *APIToken) AllowsIP(ip string) bool {
+	if len(t.AllowedIPs) == 0 {
+		return true
+	}
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, cidr := range t.AllowedIPs {
+		if _, network, err := net.ParseCIDR(cidr); err == nil && network.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// NormalizeAllowedIPs validates an IP allow-list and returns it in CIDR form.
+// Bare addresses become single-host ranges (/32 or /128).
+func NormalizeAllowedIPs(entries []string) ([]string, error) {
+	if len(entries) > MaxAllowedIPs {
+		return nil, fmt.Errorf("too many allowed IP ranges (max %d)", MaxAllowedIPs)
+	}
+	normalized := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP address: %s", entry)
+			}
+			if ip.To4() != nil {
+				entry += "/32"
+			} else {
+				entry += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR range: %s", entry)
+		}
+		normalized = append(normalized, network.String())
+	}
+	return normalized, nil
+}
+
 // HasScope returns true if the token has the specified scope
 // If scopes is nil/empty, token has all permissions (inherits from user)
 func (t *APIToken) HasScope(scope string) bool {
@@ -59,16 +114,9 @@ func (t *APIToken) HasScope(scope string) bool {
 		return true // Full access
 	}
 	for _, s := range t.Scopes {
-		if s == "*" || s == scope {
+		if ScopeGrants(s, scope) {
 			return true
 		}
-		// Check wildcard scopes (e.g., "tickets:*" matches "tickets:read")
-		if len(s) > 2 && s[len(s)-2:] == ":*" {
-			prefix := s[:len(s)-1] // "tickets:"
-			if len(scope) > len(prefix) && scope[:len(prefix)] == prefix {
-				return true
-			}
-		}
 	}
 	return false
 }
@@ -78,18 +126,21 @@ type APITokenCreateRequest struct {
 	Name      string   `json:"name" binding:"required,min=1,max=100"`
 	Scopes    []string `json:"scopes,omitempty"`
 	ExpiresIn string   `json:"expires_in,omitempty"` // "30d", "90d", "1y", "never"
+	// AllowedIPs restricts the token to these CIDR ranges or addresses
+	AllowedIPs []string `json:"allowed_ips,omitempty"`
 }
 
 // APITokenCreateResponse includes the full token (shown only once)
 type APITokenCreateResponse struct {
-	ID        int64     `json:"id"`
-	Name      string    `json:"name"`
-	Prefix    string    `json:"prefix"`
-	Token     string    `json:"token"` // Full token - shown only at creation
-	Scopes    []string  `json:"scopes,omitempty"`
-	ExpiresAt *string   `json:"expires_at,omitempty"`
-	CreatedAt time.Time `json:"created_at"`
-	Warning   string    `json:"warning"`
+	ID         int64     `json:"id"`
+	Name       string    `json:"name"`
+	Prefix     string    `json:"prefix"`
+	Token      string    `json:"token"` // Full token - shown only at creation
+	Scopes     []string  `json:"scopes,omitempty"`
+	AllowedIPs []string  `json:"allowed_ips,omitempty"`
+	ExpiresAt  *string   `json:"expires_at,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+	Warning    string    `json:"warning"`
 }
 
 // APITokenListItem represents a token in list responses (no secret)
@@ -98,25 +149,13 @@ type APITokenListItem struct {
 	Name       string   `json:"name"`
 	Prefix     string   `json:"prefix"`
 	Scopes     []string `json:"scopes,omitempty"`
+	AllowedIPs []string `json:"allowed_ips,omitempty"`
 	ExpiresAt  *string  `json:"expires_at,omitempty"`
 	LastUsedAt *string  `json:"last_used_at,omitempty"`
 	CreatedAt  string   `json:"created_at"`
 	IsActive   bool     `json:"is_active"`
 }
 
-// ValidScopes defines the allowed scope values
-var ValidScopes = map[string]string{
-	"*":              "Full access (inherits all user permissions)",
-	"tickets:read":   "View tickets",
-	"tickets:write":  "Create and update tickets",
-	"tickets:delete": "Delete tickets",
-	"articles:read":  "Read ticket articles",
-	"articles:write": "Add articles and replies",
-	"users:read":     "View user information",
-	"queues:read":    "View queue information",
-	"admin:*":        "Admin operations (agents only)",
-}
-
 // TokenPrefix is the prefix for all API tokens
 const TokenPrefix = "gf_"
 
@@ -128,3 +167,6 @@ const TokenPrefixLength = 8
 
 // DefaultRateLimit is the default rate limit for new tokens (requests per hour)
 const DefaultRateLimit = 1000
+
+// MaxAllowedIPs is the maximum number of CIDR ranges in a token's allow-list
+const MaxAllowedIPs = 50
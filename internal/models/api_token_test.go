@@ -181,3 +181,58 @@ func TestTokenFormat(t *testing.T) {
 		t.Errorf("DefaultRateLimit = %d, want %d", DefaultRateLimit, 1000)
 	}
 }
+
+func TestAPIToken_AllowsIP(t *testing.T) {
+	tests := []struct {
+		name    string
+		allowed []string
+		ip      string
+		want    bool
+	}{
+		{"no allow-list", nil, "203.0.113.7", true},
+		{"inside range", []string{"10.20.0.0/16"}, "10.20.3.4", true},
+		{"outside range", []string{"10.20.0.0/16"}, "10.21.0.1", false},
+		{"second range matches", []string{"10.0.0.0/8", "192.0.2.10/32"}, "192.0.2.10", true},
+		{"ipv6 range", []string{"2001:db8::/32"}, "2001:db8::1", true},
+		{"unparseable client ip", []string{"10.0.0.0/8"}, "not-an-ip", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := &APIToken{AllowedIPs: tt.allowed}
+			if got := token.AllowsIP(tt.ip); got != tt.want {
+				t.Errorf("AllowsIP(%q) = %v, want %v", tt.ip, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeAllowedIPs(t *testing.T) {
+	got, err := NormalizeAllowedIPs([]string{" 192.0.2.10 ", "10.1.2.3/8", "2001:db8::1", ""})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := []string{"192.0.2.10/32", "10.0.0.0/8", "2001:db8::1/128"}
+	if len(got) != len(want) {
+		t.Fatalf("NormalizeAllowedIPs = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("entry %d = %q, want %q", i, got[i], want[i])
+		}
+	}
+
+	for _, bad := range []string{"10.0.0.0/33", "example.com", "300.1.1.1"} {
+		if _, err := NormalizeAllowedIPs([]string{bad}); err == nil {
+			t.Errorf("NormalizeAllowedIPs(%q) expected error", bad)
+		}
+	}
+
+	tooMany := make([]string, MaxAllowedIPs+1)
+	for i := range tooMany {
+		tooMany[i] = "10.0.0.1"
+	}
+	if _, err := NormalizeAllowedIPs(tooMany); err == nil {
+		t.Error("expected error for oversized allow-list")
+	}
+}
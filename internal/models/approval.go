@@ -0,0 +1,87 @@
+package models
+
+import "time"
+
+// ApprovalAction is a ticket action an approval rule can guard.
+type ApprovalAction string
+
+const (
+	ApprovalActionClose ApprovalAction = "close" // Setting a state of type closed
+	ApprovalActionMove  ApprovalAction = "move"  // Moving the ticket out of its queue
+)
+
+// IsValid reports whether a is a known action.
+func (a ApprovalAction) IsValid() bool {
+	switch a {
+	case ApprovalActionClose, ApprovalActionMove:
+		return true
+	}
+	return false
+}
+
+// ApprovalStatus is the state of an approval request.
+type ApprovalStatus string
+
+const (
+	ApprovalStatusPending   ApprovalStatus = "pending"
+	ApprovalStatusApproved  ApprovalStatus = "approved"
+	ApprovalStatusRejected  ApprovalStatus = "rejected"
+	ApprovalStatusCancelled ApprovalStatus = "cancelled" // Withdrawn by the requester
+)
+
+// IsValid reports whether s is a known status.
+func (s ApprovalStatus) IsValid() bool {
+	switch s {
+	case ApprovalStatusPending, ApprovalStatusApproved, ApprovalStatusRejected, ApprovalStatusCancelled:
+		return true
+	}
+	return false
+}
+
+// ApprovalRule requires members of a group to approve an action on tickets
+// in a queue (ticket_approval_rule table).
+type ApprovalRule struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	// QueueID is the queue the rule protects; nil applies it to every queue.
+	QueueID         *int           `json:"queue_id,omitempty"`
+	Action          ApprovalAction `json:"action"`
+	ApproverGroupID int            `json:"approver_group_id"`
+	Comments        string         `json:"comments,omitempty"`
+	ValidID         int            `json:"valid_id"`
+	CreateTime      time.Time      `json:"create_time"`
+	CreateBy        int            `json:"create_by"`
+	ChangeTime      time.Time      `json:"change_time"`
+	ChangeBy        int            `json:"change_by"`
+}
+
+// ApprovalRequest is an action waiting for, or decided by, an approver
+// (ticket_approval_request table).
+type ApprovalRequest struct {
+	ID           int            `json:"id"`
+	RuleID       int            `json:"rule_id"`
+	RuleName     string         `json:"rule_name,omitempty"`
+	TicketID     int            `json:"ticket_id"`
+	TicketNumber string         `json:"ticket_number,omitempty"`
+	QueueID      int            `json:"queue_id,omitempty"`
+	Action       ApprovalAction `json:"action"`
+	// TargetID is the state to close with or the queue to move to.
+	TargetID        int            `json:"target_id"`
+	Reason          string         `json:"reason,omitempty"`
+	Status          ApprovalStatus `json:"status"`
+	RequestedBy     int            `json:"requested_by"`
+	RequestedTime   time.Time      `json:"requested_time"`
+	DecidedBy       *int           `json:"decided_by,omitempty"`
+	DecidedTime     *time.Time     `json:"decided_time,omitempty"`
+	DecisionComment string         `json:"decision_comment,omitempty"`
+	ApproverGroupID int            `json:"approver_group_id,omitempty"`
+}
+
+// ApprovalRequestFilter selects approval requests to list.
+type ApprovalRequestFilter struct {
+	Status   ApprovalStatus
+	TicketID int
+	// VisibleTo limits the list to requests the agent raised or may decide;
+	// 0 lists every request.
+	VisibleTo int
+}
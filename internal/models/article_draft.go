@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// ArticleDraft is an agent's in-progress reply to a ticket, autosaved while
+// they type so it survives a closed tab or a browser crash. Each agent has at
+// most one draft per ticket. Shared drafts can be read and edited by the
+// other agents with access to the ticket.
+type ArticleDraft struct {
+	ID                   int64     `json:"id"`
+	TicketID             int64     `json:"ticket_id"`
+	UserID               int       `json:"user_id"` // Author
+	Shared               bool      `json:"shared"`
+	Subject              string    `json:"subject"`
+	Body                 string    `json:"body"`
+	To                   string    `json:"to,omitempty"`
+	Cc                   string    `json:"cc,omitempty"`
+	Bcc                  string    `json:"bcc,omitempty"`
+	ContentType          string    `json:"content_type"`
+	IsVisibleForCustomer bool      `json:"is_visible_for_customer"`
+	BaseArticleID        int64     `json:"base_article_id"` // Newest article the author had seen
+	Version              int       `json:"version"`         // Bumped on every save
+	CreateTime           time.Time `json:"create_time"`
+	ChangeTime           time.Time `json:"change_time"`
+	ChangeBy             int       `json:"change_by"`
+
+	// Conflict is set when others posted to the ticket after BaseArticleID.
+	Conflict *ArticleDraftConflict `json:"conflict,omitempty"`
+}
+
+// ArticleDraftConflict describes the articles others posted to a ticket
+// since the author of a draft last synced it.
+type ArticleDraftConflict struct {
+	Articles        int   `json:"articles"`          // Number of newer articles
+	LatestArticleID int64 `json:"latest_article_id"` // Send as base_article_id to acknowledge
+	LatestBy        int   `json:"latest_by"`
+}
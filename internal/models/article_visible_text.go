@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// ArticleVisibleText is the part of an inbound email article the sender
+// wrote, without the earlier messages they quoted and their signature. It
+// is in the format of the article body, which is kept unchanged.
+type ArticleVisibleText struct {
+	ArticleID    int64     `json:"article_id"`
+	VisibleText  string    `json:"visible_text"`
+	HasQuote     bool      `json:"has_quote"`
+	HasSignature bool      `json:"has_signature"`
+	CreateTime   time.Time `json:"create_time"`
+}
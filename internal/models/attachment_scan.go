@@ -0,0 +1,52 @@
+package models
+
+import "time"
+
+// AttachmentScanStatus is the antivirus status of an attachment.
+type AttachmentScanStatus string
+
+const (
+	ScanStatusClean    AttachmentScanStatus = "clean"
+	ScanStatusInfected AttachmentScanStatus = "infected"
+	ScanStatusError    AttachmentScanStatus = "error"    // The scanner could not check it
+	ScanStatusReleased AttachmentScanStatus = "released" // Released from quarantine by an admin
+)
+
+// Where a scanned attachment came from.
+const (
+	AttachmentSourceUpload = "upload"
+	AttachmentSourceEmail  = "email"
+)
+
+// AttachmentScan records the scan of an attachment of an article.
+type AttachmentScan struct {
+	ID        int64                `json:"id"`
+	ArticleID int64                `json:"article_id"`
+	Filename  string               `json:"filename"`
+	Status    AttachmentScanStatus `json:"status"`
+	Signature string               `json:"signature,omitempty"` // Malware found, or the scanner error
+	Scanner   string               `json:"scanner"`
+	Source    string               `json:"source"`
+	ScannedAt time.Time            `json:"scanned_at"`
+}
+
+// QuarantinedAttachment is an attachment held back from its article
+// because it is infected or could not be scanned.
+type QuarantinedAttachment struct {
+	ID           int64                `json:"id"`
+	TicketID     int64                `json:"ticket_id"`
+	TicketNumber string               `json:"ticket_number,omitempty"`
+	ArticleID    int64                `json:"article_id"`
+	Filename     string               `json:"filename"`
+	ContentType  string               `json:"content_type"`
+	ContentSize  int64                `json:"content_size"`
+	Content      []byte               `json:"-"`
+	Status       AttachmentScanStatus `json:"status"`
+	Signature    string               `json:"signature,omitempty"`
+	Scanner      string               `json:"scanner"`
+	Source       string               `json:"source"`
+	CreateTime   time.Time            `json:"create_time"`
+	CreateBy     int                  `json:"create_by"`
+	ReleasedAt   *time.Time           `json:"released_at,omitempty"`
+	ReleasedBy   *int                 `json:"released_by,omitempty"`
+}
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// AttachmentUpload is a chunked upload of a large attachment in progress.
+// Chunks are appended in order until ReceivedSize reaches TotalSize; the
+// assembled file is then attached to the ticket.
+type AttachmentUpload struct {
+	ID           string    `json:"id"`
+	TicketID     int       `json:"ticket_id"`
+	ArticleID    int       `json:"article_id,omitempty"` // Zero attaches to the latest article
+	Filename     string    `json:"filename"`
+	ContentType  string    `json:"content_type,omitempty"`
+	TotalSize    int64     `json:"total_size"`
+	ReceivedSize int64     `json:"received_size"`
+	UserID       int       `json:"user_id"`
+	UserRole     string    `json:"user_role"` // Role whose attachment policy applies
+	CreateTime   time.Time `json:"create_time"`
+	ChangeTime   time.Time `json:"change_time"`
+}
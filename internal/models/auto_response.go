@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// Auto-response types as seeded in auto_response_type.
+const (
+	AutoResponseTypeReply          = 1 // New ticket created from an email
+	AutoResponseTypeReject         = 2 // Follow-up rejected by the queue
+	AutoResponseTypeFollowUp       = 3 // Follow-up added to a ticket
+	AutoResponseTypeReplyNewTicket = 4 // Follow-up turned into a new ticket
+	AutoResponseTypeRemove         = 5
+)
+
+// AutoResponse is an email template sent automatically when customer mail
+// arrives in a queue it is assigned to. Subject and body may contain
+// <OTRS_*> / <GOATFLOW_*> placeholders.
+type AutoResponse struct {
+	ID              int       `json:"id"`
+	Name            string    `json:"name"`
+	TypeID          int       `json:"type_id"`
+	TypeName        string    `json:"type_name,omitempty"`
+	SystemAddressID int       `json:"system_address_id"` // Sender
+	Subject         string    `json:"subject"`           // text0
+	Body            string    `json:"body"`              // text1
+	ContentType     string    `json:"content_type"`
+	Comments        string    `json:"comments,omitempty"`
+	ValidID         int       `json:"valid_id"`
+	QueueIDs        []int     `json:"queue_ids"`
+	CreateTime      time.Time `json:"create_time"`
+	CreateBy        int       `json:"create_by"`
+	ChangeTime      time.Time `json:"change_time"`
+	ChangeBy        int       `json:"change_by"`
+}
+
+// AutoResponseType is an event auto responses are sent for.
+type AutoResponseType struct {
+	ID       int    `json:"id"`
+	Name     string `json:"name"`
+	Comments string `json:"comments,omitempty"`
+}
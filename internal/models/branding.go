@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// Branding asset kinds.
+const (
+	BrandingAssetLogo    = "logo"
+	BrandingAssetFavicon = "favicon"
+)
+
+// BrandingAsset is an image of the branding, global (empty Scope) or of the
+// customer company whose ID is Scope.
+type BrandingAsset struct {
+	ID          int       `json:"id"`
+	Scope       string    `json:"scope"`
+	Kind        string    `json:"kind"`
+	FileName    string    `json:"filename"`
+	ContentType string    `json:"content_type"`
+	Size        int       `json:"size"`
+	Checksum    string    `json:"checksum"`
+	Content     []byte    `json:"-"`
+	CreateTime  time.Time `json:"create_time"`
+	CreateBy    int       `json:"create_by"`
+}
@@ -0,0 +1,65 @@
+package models
+
+import "time"
+
+// Calendar is a team calendar. Agents with ro permission on its group see
+// its appointments; rw permission lets them create and change appointments.
+type Calendar struct {
+	ID         int64     `json:"id"`
+	GroupID    int       `json:"group_id"`
+	Name       string    `json:"name"`
+	Color      string    `json:"color"` // #rrggbb
+	SaltString string    `json:"-"`     // Keys the calendar's feed tokens
+	ValidID    int       `json:"valid_id"`
+	CreateTime time.Time `json:"create_time"`
+	CreateBy   int       `json:"create_by"`
+	ChangeTime time.Time `json:"change_time"`
+	ChangeBy   int       `json:"change_by"`
+}
+
+// CalendarAppointment is an appointment in a calendar. All-day appointments
+// start at midnight UTC and end at midnight UTC after their last day.
+type CalendarAppointment struct {
+	ID          int64                       `json:"id"`
+	ParentID    int64                       `json:"parent_id,omitempty"` // Set on occurrences of recurring appointments
+	CalendarID  int64                       `json:"calendar_id"`
+	UniqueID    string                      `json:"unique_id"` // iCalendar UID
+	Title       string                      `json:"title"`
+	Description string                      `json:"description"`
+	Location    string                      `json:"location"`
+	StartTime   time.Time                   `json:"start_time"`
+	EndTime     time.Time                   `json:"end_time"`
+	AllDay      bool                        `json:"all_day"`
+	AttendeeIDs []int                       `json:"attendee_ids"`
+	Tickets     []CalendarAppointmentTicket `json:"tickets"`
+	CreateTime  time.Time                   `json:"create_time"`
+	CreateBy    int                         `json:"create_by"`
+	ChangeTime  time.Time                   `json:"change_time"`
+	ChangeBy    int                         `json:"change_by"`
+}
+
+// CalendarAppointmentTicket is a ticket linked to an appointment.
+type CalendarAppointmentTicket struct {
+	ID     int64  `json:"id"`
+	Number string `json:"number"`
+	Title  string `json:"title"`
+}
+
+// TicketIDs returns the IDs of the appointment's linked tickets.
+func (a *CalendarAppointment) TicketIDs() []int64 {
+	ids := make([]int64, 0, len(a.Tickets))
+	for _, t := range a.Tickets {
+		ids = append(ids, t.ID)
+	}
+	return ids
+}
+
+// CalendarAppointmentFilter selects appointments. Zero fields do not filter.
+type CalendarAppointmentFilter struct {
+	CalendarIDs []int64   // Appointments of these calendars; empty selects none
+	From        time.Time // Appointments ending after From
+	To          time.Time // Appointments starting before To
+	TicketID    int64     // Appointments linked to this ticket
+	AttendeeID  int       // Appointments this agent attends
+	Limit       int
+}
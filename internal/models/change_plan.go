@@ -0,0 +1,130 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// IsValid reports whether r is a known risk level.
+func (r ChangeRisk) IsValid() bool {
+	switch r {
+	case ChangeRiskLow, ChangeRiskMedium, ChangeRiskHigh, ChangeRiskCritical:
+		return true
+	}
+	return false
+}
+
+// ChangePlan is the planned window and scope of a change ticket
+// (ticket_change_plan table). A ticket with a plan is a change ticket.
+type ChangePlan struct {
+	TicketID     int    `json:"ticket_id"`
+	TicketNumber string `json:"ticket_number,omitempty"`
+	Title        string `json:"title,omitempty"`
+	QueueID      int    `json:"queue_id,omitempty"`
+	State        string `json:"state,omitempty"`
+
+	PlannedStart       time.Time  `json:"planned_start"`
+	PlannedEnd         time.Time  `json:"planned_end"`
+	Risk               ChangeRisk `json:"risk"`
+	ImplementationPlan string     `json:"implementation_plan,omitempty"`
+	BackoutPlan        string     `json:"backout_plan,omitempty"`
+	// ServiceIDs and ConfigItems are the services and configuration items
+	// the change touches.
+	ServiceIDs  []int    `json:"service_ids"`
+	ConfigItems []string `json:"config_items"`
+
+	// ApprovalStatus is the status of the latest approval request raised
+	// for the ticket; empty when none was raised.
+	ApprovalStatus ApprovalStatus `json:"approval_status,omitempty"`
+	// Conflicts lists other changes touching the same services or items in
+	// an overlapping window.
+	Conflicts []ChangeConflict `json:"conflicts,omitempty"`
+
+	CreateTime time.Time `json:"create_time"`
+	CreateBy   int       `json:"create_by"`
+	ChangeTime time.Time `json:"change_time"`
+	ChangeBy   int       `json:"change_by"`
+}
+
+// ChangeConflict is another change that touches some of the same services
+// or configuration items in an overlapping window.
+type ChangeConflict struct {
+	TicketID       int            `json:"ticket_id"`
+	TicketNumber   string         `json:"ticket_number"`
+	Title          string         `json:"title"`
+	QueueID        int            `json:"queue_id"`
+	PlannedStart   time.Time      `json:"planned_start"`
+	PlannedEnd     time.Time      `json:"planned_end"`
+	ApprovalStatus ApprovalStatus `json:"approval_status,omitempty"`
+	// ServiceIDs and ConfigItems are the shared services and items.
+	ServiceIDs  []int    `json:"service_ids"`
+	ConfigItems []string `json:"config_items"`
+}
+
+// ChangeCalendarFilter selects the changes shown in the change calendar.
+type ChangeCalendarFilter struct {
+	From time.Time
+	To   time.Time
+	// ServiceID and ConfigItem limit the calendar to changes touching them.
+	ServiceID  int
+	ConfigItem string
+	// QueueIDs limits the calendar to tickets in these queues, e.g. the
+	// queues the agent can read; nil lists every change.
+	QueueIDs []int
+	// ActiveOnly leaves out changes whose ticket is closed, merged or
+	// removed.
+	ActiveOnly bool
+}
+
+// ChangeConfigItemKey returns the key configuration item names are matched
+// by: trimmed and case-insensitive.
+func ChangeConfigItemKey(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// Overlaps reports whether the plans' windows overlap. Windows are half-open,
+// so a change starting when another ends does not collide with it.
+func (p *ChangePlan) Overlaps(other *ChangePlan) bool {
+	return p.PlannedStart.Before(other.PlannedEnd) && other.PlannedStart.Before(p.PlannedEnd)
+}
+
+// ConflictWith returns the conflict between the plans, or nil when their
+// windows do not overlap or they share no service or configuration item.
+func (p *ChangePlan) ConflictWith(other *ChangePlan) *ChangeConflict {
+	if p.TicketID == other.TicketID || !p.Overlaps(other) {
+		return nil
+	}
+	conflict := &ChangeConflict{
+		TicketID:       other.TicketID,
+		TicketNumber:   other.TicketNumber,
+		Title:          other.Title,
+		QueueID:        other.QueueID,
+		PlannedStart:   other.PlannedStart,
+		PlannedEnd:     other.PlannedEnd,
+		ApprovalStatus: other.ApprovalStatus,
+		ServiceIDs:     make([]int, 0),
+		ConfigItems:    make([]string, 0),
+	}
+	services := make(map[int]bool, len(p.ServiceIDs))
+	for _, id := range p.ServiceIDs {
+		services[id] = true
+	}
+	for _, id := range other.ServiceIDs {
+		if services[id] {
+			conflict.ServiceIDs = append(conflict.ServiceIDs, id)
+		}
+	}
+	items := make(map[string]bool, len(p.ConfigItems))
+	for _, name := range p.ConfigItems {
+		items[ChangeConfigItemKey(name)] = true
+	}
+	for _, name := range other.ConfigItems {
+		if items[ChangeConfigItemKey(name)] {
+			conflict.ConfigItems = append(conflict.ConfigItems, name)
+		}
+	}
+	if len(conflict.ServiceIDs) == 0 && len(conflict.ConfigItems) == 0 {
+		return nil
+	}
+	return conflict
+}
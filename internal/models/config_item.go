@@ -0,0 +1,120 @@
+package models
+
+import "time"
+
+// Lifecycle states of configuration items.
+const (
+	ConfigItemStatePlanned     = "planned"
+	ConfigItemStateOrdered     = "ordered"
+	ConfigItemStateInStock     = "in_stock"
+	ConfigItemStateInUse       = "in_use"
+	ConfigItemStateMaintenance = "maintenance"
+	ConfigItemStateRetired     = "retired"
+)
+
+// ConfigItemStateTransitions lists the states each lifecycle state may move
+// to. Retired items stay retired.
+var ConfigItemStateTransitions = map[string][]string{
+	ConfigItemStatePlanned:     {ConfigItemStateOrdered, ConfigItemStateInStock, ConfigItemStateInUse, ConfigItemStateRetired},
+	ConfigItemStateOrdered:     {ConfigItemStateInStock, ConfigItemStateInUse, ConfigItemStateRetired},
+	ConfigItemStateInStock:     {ConfigItemStateInUse, ConfigItemStateMaintenance, ConfigItemStateRetired},
+	ConfigItemStateInUse:       {ConfigItemStateInStock, ConfigItemStateMaintenance, ConfigItemStateRetired},
+	ConfigItemStateMaintenance: {ConfigItemStateInStock, ConfigItemStateInUse, ConfigItemStateRetired},
+	ConfigItemStateRetired:     {},
+}
+
+// ConfigItemStateAllowed reports whether an item may move from one
+// lifecycle state to another. Staying in the same state is always allowed.
+func ConfigItemStateAllowed(from, to string) bool {
+	if from == to {
+		_, ok := ConfigItemStateTransitions[to]
+		return ok
+	}
+	for _, next := range ConfigItemStateTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Types of configuration item class attributes.
+const (
+	ConfigItemAttributeText   = "text"
+	ConfigItemAttributeNumber = "number"
+	ConfigItemAttributeDate   = "date"
+	ConfigItemAttributeBool   = "bool"
+	ConfigItemAttributeSelect = "select"
+)
+
+// ConfigItemAttribute defines an attribute the items of a class carry.
+type ConfigItemAttribute struct {
+	Key      string   `json:"key"`
+	Label    string   `json:"label"`
+	Type     string   `json:"type"`
+	Required bool     `json:"required"`
+	Options  []string `json:"options,omitempty"` // Values of select attributes
+}
+
+// ConfigItemClass is a kind of configuration item, e.g. server or laptop,
+// with the attributes its items carry.
+type ConfigItemClass struct {
+	ID          int                   `json:"id"`
+	Name        string                `json:"name"`
+	Description string                `json:"description"`
+	Attributes  []ConfigItemAttribute `json:"attributes"`
+	ValidID     int                   `json:"valid_id"`
+	CreateTime  time.Time             `json:"create_time"`
+	CreateBy    int                   `json:"create_by"`
+	ChangeTime  time.Time             `json:"change_time"`
+	ChangeBy    int                   `json:"change_by"`
+}
+
+// Attribute returns the class's attribute with the key, or nil.
+func (c *ConfigItemClass) Attribute(key string) *ConfigItemAttribute {
+	for i := range c.Attributes {
+		if c.Attributes[i].Key == key {
+			return &c.Attributes[i]
+		}
+	}
+	return nil
+}
+
+// ConfigItem is an asset or configuration item recorded in the CMDB.
+type ConfigItem struct {
+	ID             int64                  `json:"id"`
+	Number         string                 `json:"number"`
+	ClassID        int                    `json:"class_id"`
+	ClassName      string                 `json:"class_name"`
+	Name           string                 `json:"name"`
+	State          string                 `json:"state"`
+	CustomerID     string                 `json:"customer_id"`      // Customer company using the item
+	CustomerUserID string                 `json:"customer_user_id"` // Customer user login using the item
+	Attributes     map[string]interface{} `json:"attributes"`
+	Tickets        []ConfigItemTicket     `json:"tickets,omitempty"` // Set when a single item is loaded
+	CreateTime     time.Time              `json:"create_time"`
+	CreateBy       int                    `json:"create_by"`
+	ChangeTime     time.Time              `json:"change_time"`
+	ChangeBy       int                    `json:"change_by"`
+}
+
+// ConfigItemTicket is a ticket linked to a configuration item.
+type ConfigItemTicket struct {
+	ID         int64     `json:"id"`
+	Number     string    `json:"number"`
+	Title      string    `json:"title"`
+	LinkedTime time.Time `json:"linked_time"`
+	LinkedBy   int       `json:"linked_by"`
+}
+
+// ConfigItemFilter selects configuration items. Zero fields do not filter.
+type ConfigItemFilter struct {
+	ClassID        int
+	State          string
+	CustomerID     string
+	CustomerUserID string
+	TicketID       int64  // Items linked to this ticket
+	Query          string // Substring of the number, name or attribute values
+	Limit          int
+	Offset         int
+}
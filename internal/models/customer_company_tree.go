@@ -0,0 +1,24 @@
+package models
+
+// MaxCustomerCompanyDepth is the most levels a customer company hierarchy
+// may have, counting the top company as one.
+const MaxCustomerCompanyDepth = 10
+
+// CustomerCompanyNode is a customer company in the company hierarchy
+// (customer_company.parent_customer_id).
+type CustomerCompanyNode struct {
+	CustomerID       string                 `json:"customer_id"`
+	Name             string                 `json:"name"`
+	ParentCustomerID string                 `json:"parent_customer_id,omitempty"`
+	ValidID          int                    `json:"valid_id"`
+	Children         []*CustomerCompanyNode `json:"children,omitempty"`
+}
+
+// CustomerCompanyHierarchy is one company's place in the hierarchy: the
+// companies above it and the subtree below it.
+type CustomerCompanyHierarchy struct {
+	// Ancestors lists the parent first and the top company last.
+	Ancestors []CustomerCompanyNode `json:"ancestors"`
+	// Company carries its subsidiaries in Children, recursively.
+	Company *CustomerCompanyNode `json:"company"`
+}
@@ -0,0 +1,69 @@
+package models
+
+// CustomerImportKind is the kind of record a customer import file holds.
+type CustomerImportKind string
+
+// Record kinds that can be imported.
+const (
+	CustomerImportUsers     CustomerImportKind = "users"
+	CustomerImportCompanies CustomerImportKind = "companies"
+)
+
+// CustomerImportAction is what an import does, or did, with a row.
+type CustomerImportAction string
+
+// Row actions. Previews plan create, update, skip or error; an import
+// reports the same actions for what it did.
+const (
+	CustomerImportCreate CustomerImportAction = "create"
+	CustomerImportUpdate CustomerImportAction = "update"
+	CustomerImportSkip   CustomerImportAction = "skip"
+	CustomerImportError  CustomerImportAction = "error"
+)
+
+// CustomerImportField is a column of customer_user or customer_company that
+// a file column can be mapped to.
+type CustomerImportField struct {
+	Name     string `json:"name"`
+	Label    string `json:"label"`
+	Required bool   `json:"required"`
+}
+
+// CustomerImportRow is one data row of an import file.
+type CustomerImportRow struct {
+	// Line is the row's line in the file, the header being line 1.
+	Line int `json:"line"`
+	// Key is the login or customer ID identifying the record.
+	Key    string               `json:"key"`
+	Action CustomerImportAction `json:"action"`
+	// Values holds the mapped fields by name. Passwords are left out.
+	Values   map[string]string `json:"values"`
+	Errors   []string          `json:"errors,omitempty"`
+	Warnings []string          `json:"warnings,omitempty"`
+}
+
+// CustomerImportPreview is a parsed import file with its column mapping and
+// the action planned for each row.
+type CustomerImportPreview struct {
+	Kind    CustomerImportKind    `json:"kind"`
+	Fields  []CustomerImportField `json:"fields"`
+	Headers []string              `json:"headers"`
+	// Mapping holds the field each column is imported into, by column;
+	// an empty name leaves the column out.
+	Mapping []string            `json:"mapping"`
+	Rows    []CustomerImportRow `json:"rows"`
+	Creates int                 `json:"creates"`
+	Updates int                 `json:"updates"`
+	Skips   int                 `json:"skips"`
+	Invalid int                 `json:"invalid"`
+}
+
+// CustomerImportResult reports what an import did with each row.
+type CustomerImportResult struct {
+	Kind    CustomerImportKind  `json:"kind"`
+	Created int                 `json:"created"`
+	Updated int                 `json:"updated"`
+	Skipped int                 `json:"skipped"`
+	Failed  int                 `json:"failed"`
+	Rows    []CustomerImportRow `json:"rows"`
+}
@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// Customer registration states.
+const (
+	CustomerRegistrationUnverified = "unverified"
+	CustomerRegistrationPending    = "pending"
+	CustomerRegistrationApproved   = "approved"
+	CustomerRegistrationRejected   = "rejected"
+)
+
+// CustomerRegistration is a customer portal signup. Its customer_user
+// account is created once the email address is verified and, in approval
+// mode, an admin approved it.
+type CustomerRegistration struct {
+	ID         int64      `json:"id"`
+	Email      string     `json:"email"`
+	FirstName  string     `json:"first_name"`
+	LastName   string     `json:"last_name"`
+	CustomerID string     `json:"customer_id"`
+	Password   string     `json:"-"` // Hash
+	Status     string     `json:"status"`
+	RemoteIP   string     `json:"remote_ip,omitempty"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	VerifiedAt *time.Time `json:"verified_at,omitempty"`
+	DecidedAt  *time.Time `json:"decided_at,omitempty"`
+	DecidedBy  *int       `json:"decided_by,omitempty"`
+	CreateTime time.Time  `json:"create_time"`
+	ChangeTime time.Time  `json:"change_time"`
+}
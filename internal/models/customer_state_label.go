@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// Customer state categories group ticket states the way customers see them;
+// the portal styles state badges by category.
+const (
+	CustomerStateNew     = "new"
+	CustomerStateOpen    = "open"
+	CustomerStatePending = "pending"
+	CustomerStateClosed  = "closed"
+)
+
+// CustomerStateLabel is the customer-facing label configured for a ticket
+// state, replacing the internal state name in the customer portal and in
+// responses to customer API tokens.
+type CustomerStateLabel struct {
+	StateID    int       `json:"state_id"`
+	Label      string    `json:"label"`
+	I18nKey    string    `json:"i18n_key,omitempty"` // Translated in place of Label when the key exists
+	CreateTime time.Time `json:"create_time"`
+	CreateBy   int       `json:"create_by"`
+	ChangeTime time.Time `json:"change_time"`
+	ChangeBy   int       `json:"change_by"`
+}
+
+// CustomerStateMapping is how customers see one ticket state: the
+// configured label, or the default for the state's type when none is set.
+type CustomerStateMapping struct {
+	StateID   int    `json:"state_id"`
+	StateName string `json:"state_name"` // Internal name, never shown to customers
+	StateType string `json:"state_type"`
+	Category  string `json:"category"` // new, open, pending or closed
+	Label     string `json:"label"`
+	I18nKey   string `json:"i18n_key,omitempty"`
+	Custom    bool   `json:"custom"` // Label configured rather than derived from the state type
+}
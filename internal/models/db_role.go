@@ -51,3 +51,22 @@ var PermissionTypes = []string{
 	"priority",  // Change ticket priority
 	"rw",        // Read/Write - full access (implies all others)
 }
+
+// RoleGroupPermissions is the set of permission keys a role grants in one
+// group (the group_role rows with permission_value = 1).
+type RoleGroupPermissions struct {
+	GroupID     int      `json:"group_id"`
+	GroupName   string   `json:"group_name,omitempty"`
+	Permissions []string `json:"permissions"`
+}
+
+// EffectiveGroupPermission is a permission key an agent holds in a group,
+// with where it comes from: a direct group_user assignment, one or more
+// valid roles, or both.
+type EffectiveGroupPermission struct {
+	GroupID    int      `json:"group_id"`
+	GroupName  string   `json:"group_name"`
+	Permission string   `json:"permission"`
+	Direct     bool     `json:"direct"`
+	Roles      []string `json:"roles,omitempty"`
+}
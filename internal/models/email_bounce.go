@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// Bounce types.
+const (
+	BounceTypeHard = "hard" // Permanent failure (DSN status 5.x.x)
+	BounceTypeSoft = "soft" // Temporary failure (DSN status 4.x.x)
+)
+
+// EmailBounce is the bounce state of an email address, built from the
+// bounce reports received for mail sent to it.
+type EmailBounce struct {
+	ID              int       `json:"id"`
+	Email           string    `json:"email"`
+	BounceType      string    `json:"bounce_type"` // Type of the last bounce
+	Status          string    `json:"status,omitempty"`
+	Diagnostic      string    `json:"diagnostic,omitempty"`
+	HardCount       int       `json:"hard_count"`
+	SoftCount       int       `json:"soft_count"`
+	FirstBounceTime time.Time `json:"first_bounce_time"`
+	LastBounceTime  time.Time `json:"last_bounce_time"`
+
+	// Paused is set when auto responses to the address are paused.
+	Paused bool `json:"paused"`
+}
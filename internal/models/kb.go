@@ -0,0 +1,82 @@
+package models
+
+import "time"
+
+// KBVisibility controls who can read a knowledge base article.
+type KBVisibility string
+
+const (
+	KBVisibilityInternal KBVisibility = "internal" // Agents only
+	KBVisibilityCustomer KBVisibility = "customer" // Agents and logged-in customers
+	KBVisibilityPublic   KBVisibility = "public"   // Everyone, including anonymous visitors
+)
+
+// IsValid reports whether v is a known visibility.
+func (v KBVisibility) IsValid() bool {
+	switch v {
+	case KBVisibilityInternal, KBVisibilityCustomer, KBVisibilityPublic:
+		return true
+	}
+	return false
+}
+
+// KBCategory groups knowledge base articles (kb_category table).
+type KBCategory struct {
+	ID         int       `json:"id"`
+	Name       string    `json:"name"`
+	ParentID   *int      `json:"parent_id,omitempty"`
+	Comments   string    `json:"comments,omitempty"`
+	ValidID    int       `json:"valid_id"`
+	CreateTime time.Time `json:"create_time"`
+	CreateBy   int       `json:"create_by"`
+	ChangeTime time.Time `json:"change_time"`
+	ChangeBy   int       `json:"change_by"`
+}
+
+// KBArticle is the current revision of a knowledge base article (kb_article table).
+type KBArticle struct {
+	ID           int          `json:"id"`
+	CategoryID   int          `json:"category_id"`
+	CategoryName string       `json:"category_name,omitempty"`
+	Title        string       `json:"title"`
+	Body         string       `json:"body"`
+	Keywords     string       `json:"keywords,omitempty"`
+	Visibility   KBVisibility `json:"visibility"`
+	Version      int          `json:"version"`
+	ValidID      int          `json:"valid_id"`
+	CreateTime   time.Time    `json:"create_time"`
+	CreateBy     int          `json:"create_by"`
+	ChangeTime   time.Time    `json:"change_time"`
+	ChangeBy     int          `json:"change_by"`
+}
+
+// KBArticleVersion is a saved revision of an article (kb_article_version table).
+type KBArticleVersion struct {
+	ID         int          `json:"id"`
+	ArticleID  int          `json:"article_id"`
+	Version    int          `json:"version"`
+	Title      string       `json:"title"`
+	Body       string       `json:"body"`
+	Keywords   string       `json:"keywords,omitempty"`
+	Visibility KBVisibility `json:"visibility"`
+	ChangeNote string       `json:"change_note,omitempty"`
+	CreateTime time.Time    `json:"create_time"`
+	CreateBy   int          `json:"create_by"`
+}
+
+// KBArticleFilter narrows article listings and searches.
+type KBArticleFilter struct {
+	CategoryID int
+	Query      string
+	// Visibilities limits results to these visibilities; empty means all.
+	Visibilities []KBVisibility
+	Limit        int
+	Offset       int
+}
+
+// KBSuggestion is an article proposed for a ticket, with its match score.
+type KBSuggestion struct {
+	Article KBArticle `json:"article"`
+	Score   float64   `json:"score"`
+	Matched []string  `json:"matched_terms"`
+}
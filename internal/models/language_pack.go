@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// LanguagePack holds the runtime translations of one language, layered over
+// the built-in translation files. Entries map dot-notation keys to text.
+type LanguagePack struct {
+	ID         int               `json:"id"`
+	Language   string            `json:"language"`
+	EntryCount int               `json:"entry_count"`
+	Checksum   string            `json:"checksum"`
+	Entries    map[string]string `json:"-"`
+	CreateTime time.Time         `json:"create_time"`
+	CreateBy   int               `json:"create_by"`
+}
@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// PasswordResetRequest is a forgot-password request of an agent or
+// customer. Requests for logins without an account are recorded too, with
+// no token, so every request counts toward the account's limit.
+type PasswordResetRequest struct {
+	ID         int64      `json:"id"`
+	UserType   string     `json:"user_type"`
+	Login      string     `json:"login"`
+	RemoteIP   string     `json:"remote_ip,omitempty"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	UsedAt     *time.Time `json:"used_at,omitempty"`
+	CreateTime time.Time  `json:"create_time"`
+}
+
+// PasswordResetLockout blocks password resets of an account that requested
+// too many.
+type PasswordResetLockout struct {
+	UserType    string    `json:"user_type"`
+	Login       string    `json:"login"`
+	LockedUntil time.Time `json:"locked_until"`
+	CreateTime  time.Time `json:"create_time"`
+}
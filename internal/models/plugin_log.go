@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// PluginLogEntry is one log line written by a plugin, or by the host about
+// a plugin. ID is set once the entry is stored.
+type PluginLogEntry struct {
+	ID        int64          `json:"id,omitempty"`
+	Timestamp time.Time      `json:"timestamp"`
+	Plugin    string         `json:"plugin"`
+	Level     string         `json:"level"` // debug, info, warn, error
+	Message   string         `json:"message"`
+	Fields    map[string]any `json:"fields,omitempty"`
+}
+
+// PluginLogQuery selects stored plugin log entries. Zero From and To leave
+// the range open; Level keeps entries at or above it.
+type PluginLogQuery struct {
+	Plugin string
+	Level  string
+	From   time.Time
+	To     time.Time
+	Limit  int
+}
@@ -0,0 +1,87 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// PortalCertStatus is where a portal domain's TLS certificate stands.
+type PortalCertStatus string
+
+const (
+	// PortalCertPending: no certificate has been requested yet, or the
+	// certificate hook is not configured.
+	PortalCertPending PortalCertStatus = "pending"
+	// PortalCertRequested: the certificate hook accepted the request.
+	PortalCertRequested PortalCertStatus = "requested"
+	// PortalCertIssued: the provisioning system reported the certificate
+	// as installed.
+	PortalCertIssued PortalCertStatus = "issued"
+	// PortalCertFailed: the hook or the provisioning system failed.
+	PortalCertFailed PortalCertStatus = "failed"
+)
+
+// IsValid reports whether s is a known certificate status.
+func (s PortalCertStatus) IsValid() bool {
+	switch s {
+	case PortalCertPending, PortalCertRequested, PortalCertIssued, PortalCertFailed:
+		return true
+	}
+	return false
+}
+
+// PortalDomain is a custom host name serving a branded customer portal for
+// one customer company (customer_portal_domain table). Only the company's
+// customer users can sign in on it.
+type PortalDomain struct {
+	ID         int    `json:"id"`
+	Domain     string `json:"domain"`
+	CustomerID string `json:"customer_id"`
+	// Theme is the theme ID the portal is shown in; empty keeps each
+	// customer's own choice.
+	Theme   string `json:"theme,omitempty"`
+	LogoURL string `json:"logo_url,omitempty"`
+	// SSOProviderID is the customer identity provider offered on the login
+	// page instead of all enabled ones; 0 offers all of them.
+	SSOProviderID int `json:"sso_provider_id,omitempty"`
+	// PasswordLogin allows password sign-in next to the identity provider.
+	PasswordLogin bool `json:"password_login"`
+	// QueueIDs restricts the tickets the portal shows; new tickets go to the
+	// first queue. Empty means no restriction.
+	QueueIDs       []int            `json:"queue_ids"`
+	CertStatus     PortalCertStatus `json:"cert_status"`
+	CertMessage    string           `json:"cert_message,omitempty"`
+	CertChangeTime *time.Time       `json:"cert_change_time,omitempty"`
+	ValidID        int              `json:"valid_id"`
+	CreateTime     time.Time        `json:"create_time"`
+	CreateBy       int              `json:"create_by"`
+	ChangeTime     time.Time        `json:"change_time"`
+	ChangeBy       int              `json:"change_by"`
+}
+
+// AllowsQueue reports whether tickets in the queue are shown on the portal.
+func (d *PortalDomain) AllowsQueue(queueID int) bool {
+	if len(d.QueueIDs) == 0 {
+		return true
+	}
+	for _, id := range d.QueueIDs {
+		if id == queueID {
+			return true
+		}
+	}
+	return false
+}
+
+// NormalizePortalHost lowercases a request host and strips the port and a
+// trailing dot, giving the form portal domains are stored in.
+func NormalizePortalHost(host string) string {
+	host = strings.ToLower(strings.TrimSpace(host))
+	if strings.HasPrefix(host, "[") {
+		// IPv6 literals are never portal domains.
+		return ""
+	}
+	if i := strings.LastIndex(host, ":"); i >= 0 {
+		host = host[:i]
+	}
+	return strings.TrimSuffix(host, ".")
+}
@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+// Kinds of priority matrix levels.
+const (
+	PriorityMatrixImpact  = "impact"
+	PriorityMatrixUrgency = "urgency"
+)
+
+// PriorityMatrixLevel is an impact or urgency level tickets can be rated
+// with, e.g. "High" or "Department".
+type PriorityMatrixLevel struct {
+	ID         int       `json:"id"`
+	Kind       string    `json:"kind"` // impact or urgency
+	Name       string    `json:"name"`
+	Position   int       `json:"position"` // Display order, lowest first
+	ValidID    int       `json:"valid_id"`
+	CreateTime time.Time `json:"create_time"`
+	CreateBy   int       `json:"create_by"`
+	ChangeTime time.Time `json:"change_time"`
+	ChangeBy   int       `json:"change_by"`
+}
+
+// PriorityMatrixCell maps an impact and urgency pair to a ticket priority.
+// QueueID 0 is the default matrix every queue falls back to.
+type PriorityMatrixCell struct {
+	QueueID    int `json:"queue_id"`
+	ImpactID   int `json:"impact_id"`
+	UrgencyID  int `json:"urgency_id"`
+	PriorityID int `json:"priority_id"`
+}
+
+// PriorityMatrix is the matrix of one queue, or the default matrix when
+// QueueID is 0.
+type PriorityMatrix struct {
+	QueueID   int                   `json:"queue_id"`
+	Impacts   []PriorityMatrixLevel `json:"impacts"`
+	Urgencies []PriorityMatrixLevel `json:"urgencies"`
+	Cells     []PriorityMatrixCell  `json:"cells"` // Cells of a queue matrix keep QueueID 0 where they come from the default
+}
+
+// TicketImpactUrgency is the impact and urgency a ticket is rated with.
+// Zero IDs mean the ticket is not rated.
+type TicketImpactUrgency struct {
+	TicketID  int64 `json:"ticket_id"`
+	ImpactID  int   `json:"impact_id"`
+	UrgencyID int   `json:"urgency_id"`
+}
@@ -0,0 +1,105 @@
+package models
+
+import "time"
+
+// PrivacyRequestKind is what a data subject request does.
+type PrivacyRequestKind string
+
+const (
+	PrivacyRequestExport  PrivacyRequestKind = "export"  // ZIP of everything stored about the customer
+	PrivacyRequestErasure PrivacyRequestKind = "erasure" // Pseudonymize or erase the customer's data
+)
+
+// PrivacyRequestStatus is the state of a data subject request.
+type PrivacyRequestStatus string
+
+const (
+	PrivacyStatusPending   PrivacyRequestStatus = "pending" // Waiting for an admin's decision
+	PrivacyStatusApproved  PrivacyRequestStatus = "approved"
+	PrivacyStatusRejected  PrivacyRequestStatus = "rejected"
+	PrivacyStatusRunning   PrivacyRequestStatus = "running"
+	PrivacyStatusCompleted PrivacyRequestStatus = "completed"
+	PrivacyStatusFailed    PrivacyRequestStatus = "failed"
+)
+
+// PrivacyFieldAction is what an erasure does to one kind of data.
+type PrivacyFieldAction string
+
+const (
+	PrivacyKeep         PrivacyFieldAction = "keep"
+	PrivacyPseudonymize PrivacyFieldAction = "pseudonymize" // Replace with a stable placeholder
+	PrivacyErase        PrivacyFieldAction = "erase"        // Clear or delete
+)
+
+// PrivacyField is a kind of personal data an erasure can change, with the
+// actions it supports.
+type PrivacyField struct {
+	Name    string               `json:"name"`
+	Label   string               `json:"label"`
+	Actions []PrivacyFieldAction `json:"actions"`
+	Default PrivacyFieldAction   `json:"default"`
+}
+
+var (
+	allPrivacyActions  = []PrivacyFieldAction{PrivacyKeep, PrivacyPseudonymize, PrivacyErase}
+	keepOrPseudonymize = []PrivacyFieldAction{PrivacyKeep, PrivacyPseudonymize}
+	keepOrErase        = []PrivacyFieldAction{PrivacyKeep, PrivacyErase}
+)
+
+// PrivacyFields lists the fields of an erasure's field policy. Article
+// fields apply to every article of the customer's tickets.
+var PrivacyFields = []PrivacyField{
+	{"customer.login", "Customer login (also on tickets)", keepOrPseudonymize, PrivacyPseudonymize},
+	{"customer.name", "First and last name, title", allPrivacyActions, PrivacyPseudonymize},
+	{"customer.email", "Email address", allPrivacyActions, PrivacyPseudonymize},
+	{"customer.phone", "Phone, mobile and fax", allPrivacyActions, PrivacyErase},
+	{"customer.address", "Street, zip, city and country", allPrivacyActions, PrivacyErase},
+	{"customer.comments", "Comments", keepOrErase, PrivacyErase},
+	{"customer.preferences", "Preferences", keepOrErase, PrivacyErase},
+	{"ticket.title", "Ticket titles", keepOrErase, PrivacyKeep},
+	{"article.addresses", "Customer's name and address in article From/To/Cc/Reply-To", keepOrPseudonymize, PrivacyPseudonymize},
+	{"article.subject", "Article subjects", keepOrErase, PrivacyKeep},
+	{"article.body", "Article bodies", keepOrErase, PrivacyErase},
+	{"article.attachments", "Article attachments", keepOrErase, PrivacyErase},
+}
+
+// DefaultPrivacyFieldPolicy returns the default action of every field.
+func DefaultPrivacyFieldPolicy() map[string]PrivacyFieldAction {
+	policy := make(map[string]PrivacyFieldAction, len(PrivacyFields))
+	for _, f := range PrivacyFields {
+		policy[f.Name] = f.Default
+	}
+	return policy
+}
+
+// PrivacyRequest is a data subject's request to export or erase the data
+// stored about them as a customer user.
+type PrivacyRequest struct {
+	ID              int                           `json:"id"`
+	Kind            PrivacyRequestKind            `json:"kind"`
+	Subject         string                        `json:"subject"`
+	CustomerLogin   string                        `json:"customer_login"`
+	FieldPolicy     map[string]PrivacyFieldAction `json:"field_policy,omitempty"`
+	Reason          string                        `json:"reason,omitempty"`
+	Status          PrivacyRequestStatus          `json:"status"`
+	RequestedBy     int                           `json:"requested_by"`
+	RequestedAt     time.Time                     `json:"requested_at"`
+	DecidedBy       *int                          `json:"decided_by,omitempty"`
+	DecidedAt       *time.Time                    `json:"decided_at,omitempty"`
+	DecisionComment string                        `json:"decision_comment,omitempty"`
+	JobID           string                        `json:"job_id,omitempty"`
+	StartedAt       *time.Time                    `json:"started_at,omitempty"`
+	FinishedAt      *time.Time                    `json:"finished_at,omitempty"`
+	Result          map[string]int                `json:"result,omitempty"`
+	Error           string                        `json:"error,omitempty"`
+}
+
+// PrivacyRequestLog is an entry in the audit trail of a request.
+type PrivacyRequestLog struct {
+	ID         int       `json:"id"`
+	RequestID  int       `json:"request_id"`
+	Action     string    `json:"action"`
+	Message    string    `json:"message,omitempty"`
+	CreateTime time.Time `json:"create_time"`
+	CreateBy   int       `json:"create_by"`
+}
@@ -0,0 +1,59 @@
+package models
+
+import "time"
+
+// QueueStatsDay holds the ticket statistics of one queue for one day, as
+// aggregated by the nightly queue statistics job. Durations are kept as
+// sums with their counts so that averages over several days are exact.
+type QueueStatsDay struct {
+	QueueID   int       `json:"queue_id"`
+	QueueName string    `json:"-"`
+	Date      time.Time `json:"date"`
+	// OpenCount and PendingCount are a snapshot taken when the day was
+	// aggregated; they are nil for days aggregated afterwards.
+	OpenCount            *int      `json:"open_count,omitempty"`
+	PendingCount         *int      `json:"pending_count,omitempty"`
+	CreatedCount         int       `json:"created_count"`
+	ClosedCount          int       `json:"closed_count"`
+	FirstResponseCount   int       `json:"first_response_count"`
+	FirstResponseSeconds int64     `json:"first_response_seconds"`
+	ResolutionCount      int       `json:"resolution_count"`
+	ResolutionSeconds    int64     `json:"resolution_seconds"`
+	CreateTime           time.Time `json:"-"`
+}
+
+// QueueStats summarises the statistics of one queue over a window of days.
+type QueueStats struct {
+	QueueID   int    `json:"queue_id"`
+	QueueName string `json:"queue_name"`
+	// Open and Pending are the counts of the latest snapshot in the window.
+	Open                    int               `json:"open"`
+	Pending                 int               `json:"pending"`
+	Created                 int               `json:"created"`
+	Closed                  int               `json:"closed"`
+	AvgFirstResponseSeconds *float64          `json:"avg_first_response_seconds"`
+	AvgResolutionSeconds    *float64          `json:"avg_resolution_seconds"`
+	Trend                   []QueueStatsPoint `json:"trend"`
+}
+
+// QueueStatsPoint is one day of a queue's trend.
+type QueueStatsPoint struct {
+	Date                    string   `json:"date"`
+	Open                    *int     `json:"open"`
+	Pending                 *int     `json:"pending"`
+	Created                 int      `json:"created"`
+	Closed                  int      `json:"closed"`
+	AvgFirstResponseSeconds *float64 `json:"avg_first_response_seconds"`
+	AvgResolutionSeconds    *float64 `json:"avg_resolution_seconds"`
+}
+
+// QueueStatsReport is the answer to a queue statistics request.
+type QueueStatsReport struct {
+	Window string `json:"window"`
+	From   string `json:"from"`
+	To     string `json:"to"`
+	// AsOf is the last aggregated day in the window, or empty when the
+	// window has no statistics yet.
+	AsOf   string       `json:"as_of,omitempty"`
+	Queues []QueueStats `json:"queues"`
+}
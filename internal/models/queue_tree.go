@@ -0,0 +1,38 @@
+package models
+
+// QueueNameSeparator separates the levels of a nested queue name: the queue
+// "Support::Hardware" is the sub-queue "Hardware" of the queue "Support".
+const QueueNameSeparator = "::"
+
+// MaxQueueNameLength is the length of the queue.name column. A nested name
+// counts in full, parents included.
+const MaxQueueNameLength = 200
+
+// QueueSettings are the columns of a queue that the queue API writes.
+type QueueSettings struct {
+	Name            string  `json:"name"`
+	GroupID         int     `json:"group_id"`
+	SystemAddressID int     `json:"system_address_id"`
+	SalutationID    int     `json:"salutation_id"`
+	SignatureID     int     `json:"signature_id"`
+	UnlockTimeout   int     `json:"unlock_timeout"`
+	FollowUpID      int     `json:"follow_up_id"`
+	FollowUpLock    int     `json:"follow_up_lock"`
+	Comments        *string `json:"comments"`
+	ValidID         int     `json:"valid_id"`
+}
+
+// QueueNode is a queue in the queue tree. The tree is derived from the
+// queue names; there is no parent column.
+type QueueNode struct {
+	ID int `json:"id"`
+	// Name is the full name, parents included.
+	Name string `json:"name"`
+	// ShortName is the last level of Name.
+	ShortName string       `json:"short_name"`
+	ParentID  int          `json:"parent_id,omitempty"`
+	Level     int          `json:"level"`
+	GroupID   int          `json:"group_id"`
+	ValidID   int          `json:"valid_id"`
+	Children  []*QueueNode `json:"children,omitempty"`
+}
@@ -0,0 +1,157 @@
+package models
+
+import "time"
+
+// ReportMetric is the value a report measures.
+type ReportMetric string
+
+const (
+	ReportMetricTicketsCreated     ReportMetric = "tickets_created"      // Tickets created in the period
+	ReportMetricTicketsClosed      ReportMetric = "tickets_closed"       // Tickets closed in the period
+	ReportMetricAvgResolutionHours ReportMetric = "avg_resolution_hours" // Mean hours from creation to close
+	ReportMetricSurveyResponses    ReportMetric = "survey_responses"     // Satisfaction surveys answered in the period
+	ReportMetricAvgSatisfaction    ReportMetric = "avg_satisfaction"     // Mean survey rating (1-5)
+)
+
+// IsSurvey reports whether m is computed from satisfaction survey responses
+// rather than tickets.
+func (m ReportMetric) IsSurvey() bool {
+	return m == ReportMetricSurveyResponses || m == ReportMetricAvgSatisfaction
+}
+
+// IsValid reports whether m is a known metric.
+func (m ReportMetric) IsValid() bool {
+	switch m {
+	case ReportMetricTicketsCreated, ReportMetricTicketsClosed, ReportMetricAvgResolutionHours,
+		ReportMetricSurveyResponses, ReportMetricAvgSatisfaction:
+		return true
+	}
+	return false
+}
+
+// ReportGrouping is the ticket attribute a report is broken down by.
+type ReportGrouping string
+
+const (
+	ReportGroupNone     ReportGrouping = "none"
+	ReportGroupQueue    ReportGrouping = "queue"
+	ReportGroupState    ReportGrouping = "state"
+	ReportGroupPriority ReportGrouping = "priority"
+	ReportGroupOwner    ReportGrouping = "owner"
+	ReportGroupCustomer ReportGrouping = "customer"
+	ReportGroupType     ReportGrouping = "type"
+)
+
+// IsValid reports whether g is a known grouping.
+func (g ReportGrouping) IsValid() bool {
+	switch g {
+	case ReportGroupNone, ReportGroupQueue, ReportGroupState, ReportGroupPriority, ReportGroupOwner, ReportGroupCustomer,
+		ReportGroupType:
+		return true
+	}
+	return false
+}
+
+// ReportBucket is the time interval report values are aggregated over.
+type ReportBucket string
+
+const (
+	ReportBucketNone  ReportBucket = "none"
+	ReportBucketDay   ReportBucket = "day"
+	ReportBucketWeek  ReportBucket = "week"
+	ReportBucketMonth ReportBucket = "month"
+)
+
+// IsValid reports whether b is a known time bucket.
+func (b ReportBucket) IsValid() bool {
+	switch b {
+	case ReportBucketNone, ReportBucketDay, ReportBucketWeek, ReportBucketMonth:
+		return true
+	}
+	return false
+}
+
+// ReportFormat is how a report result is rendered.
+type ReportFormat string
+
+const (
+	ReportFormatTable ReportFormat = "table" // JSON via the API, HTML table by email
+	ReportFormatCSV   ReportFormat = "csv"
+	ReportFormatPNG   ReportFormat = "png" // Bar chart
+)
+
+// IsValid reports whether f is a known format.
+func (f ReportFormat) IsValid() bool {
+	switch f {
+	case ReportFormatTable, ReportFormatCSV, ReportFormatPNG:
+		return true
+	}
+	return false
+}
+
+// ReportFilters restricts the tickets a report covers.
+type ReportFilters struct {
+	QueueIDs    []int `json:"queue_ids,omitempty"`
+	StateIDs    []int `json:"state_ids,omitempty"`
+	PriorityIDs []int `json:"priority_ids,omitempty"`
+	TypeIDs     []int `json:"type_ids,omitempty"`
+	// PeriodDays is how many days back from the run time the report covers.
+	PeriodDays int `json:"period_days,omitempty"`
+}
+
+// ReportDefinition is a saved report (report_definition table).
+type ReportDefinition struct {
+	ID          int            `json:"id"`
+	Name        string         `json:"name"`
+	Description string         `json:"description,omitempty"`
+	Metric      ReportMetric   `json:"metric"`
+	GroupBy     ReportGrouping `json:"group_by"`
+	TimeBucket  ReportBucket   `json:"time_bucket"`
+	Filters     ReportFilters  `json:"filters"`
+	// Schedule is a five-field cron expression; empty means not scheduled.
+	Schedule          string       `json:"schedule,omitempty"`
+	ScheduleFormat    ReportFormat `json:"schedule_format,omitempty"`
+	RecipientGroupIDs []int        `json:"recipient_group_ids,omitempty"`
+	LastSentTime      *time.Time   `json:"last_sent_time,omitempty"`
+	ValidID           int          `json:"valid_id"`
+	CreateTime        time.Time    `json:"create_time"`
+	CreateBy          int          `json:"create_by"`
+	ChangeTime        time.Time    `json:"change_time"`
+	ChangeBy          int          `json:"change_by"`
+}
+
+// ReportTicket is the ticket data a report is computed from. For survey
+// metrics there is one per answered survey, with ChangeTime the time it was
+// answered.
+type ReportTicket struct {
+	CreateTime time.Time
+	ChangeTime time.Time
+	Closed     bool
+	Rating     float64 // Mean rating of the survey response, for survey metrics
+	Queue      string
+	State      string
+	Priority   string
+	Owner      string
+	Customer   string
+	Type       string
+}
+
+// ReportRow is one aggregated value of a report result.
+type ReportRow struct {
+	Bucket string  `json:"bucket,omitempty"`
+	Group  string  `json:"group,omitempty"`
+	Value  float64 `json:"value"`
+	Count  int     `json:"count"`
+}
+
+// ReportResult is the output of running a report.
+type ReportResult struct {
+	ReportID   int            `json:"report_id"`
+	Name       string         `json:"name"`
+	Metric     ReportMetric   `json:"metric"`
+	GroupBy    ReportGrouping `json:"group_by"`
+	TimeBucket ReportBucket   `json:"time_bucket"`
+	From       time.Time      `json:"from"`
+	To         time.Time      `json:"to"`
+	Rows       []ReportRow    `json:"rows"`
+}
@@ -0,0 +1,90 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// RequestCapture is an admin-defined window during which matching API
+// requests are recorded for replay (api_request_capture table).
+type RequestCapture struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+	// Method limits the capture to one HTTP method; empty captures all.
+	Method string `json:"method,omitempty"`
+	// PathPrefix is matched against the request path, e.g. /api/v1/tickets.
+	PathPrefix string `json:"path_prefix"`
+	// Principal limits the capture to one caller: "token:<id>",
+	// "user:<id>" or "customer:<id>". Empty captures every caller.
+	Principal   string    `json:"principal,omitempty"`
+	MaxRequests int       `json:"max_requests"`
+	Captured    int       `json:"captured"`
+	ExpiresTime time.Time `json:"expires_time"`
+	ValidID     int       `json:"valid_id"`
+	CreateTime  time.Time `json:"create_time"`
+	CreateBy    int       `json:"create_by"`
+	ChangeTime  time.Time `json:"change_time"`
+	ChangeBy    int       `json:"change_by"`
+}
+
+// Active reports whether the capture still records requests at now.
+func (c *RequestCapture) Active(now time.Time) bool {
+	return c.ValidID == 1 && now.Before(c.ExpiresTime) && c.Captured < c.MaxRequests
+}
+
+// Matches reports whether a request by principal is recorded by the capture.
+func (c *RequestCapture) Matches(method, path, principal string) bool {
+	if c.Method != "" && !strings.EqualFold(c.Method, method) {
+		return false
+	}
+	if c.Principal != "" && c.Principal != principal {
+		return false
+	}
+	prefix := strings.TrimSuffix(c.PathPrefix, "/")
+	return path == prefix || strings.HasPrefix(path, prefix+"/")
+}
+
+// CapturedRequest is one recorded API request and its response
+// (api_captured_request table). Secrets are redacted before it is stored.
+type CapturedRequest struct {
+	ID              int64             `json:"id"`
+	CaptureID       int64             `json:"capture_id"`
+	Principal       string            `json:"principal"`
+	Method          string            `json:"method"`
+	Path            string            `json:"path"`
+	Query           string            `json:"query,omitempty"`
+	RequestHeaders  map[string]string `json:"request_headers"`
+	RequestBody     string            `json:"request_body,omitempty"`
+	StatusCode      int               `json:"status_code"`
+	ResponseHeaders map[string]string `json:"response_headers"`
+	ResponseBody    string            `json:"response_body,omitempty"`
+	DurationMS      int64             `json:"duration_ms"`
+	// Truncated is set when a body was cut at the size limit or omitted
+	// because it was not text.
+	Truncated  bool      `json:"truncated"`
+	CreateTime time.Time `json:"create_time"`
+}
+
+// ResponseDifference is one difference between a captured response and its
+// replay. Path is "status" for the status code, "body" for non-JSON bodies
+// and a JSON path such as data.items[0].title otherwise.
+type ResponseDifference struct {
+	Path   string      `json:"path"`
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// RequestReplay is the outcome of sending a captured request again
+// (api_request_replay table).
+type RequestReplay struct {
+	ID                int64                `json:"id"`
+	CapturedRequestID int64                `json:"captured_request_id"`
+	Target            string               `json:"target"`
+	StatusCode        int                  `json:"status_code"`
+	ResponseBody      string               `json:"response_body,omitempty"`
+	Differences       []ResponseDifference `json:"differences"`
+	Error             string               `json:"error,omitempty"`
+	DurationMS        int64                `json:"duration_ms"`
+	CreateTime        time.Time            `json:"create_time"`
+	CreateBy          int                  `json:"create_by"`
+}
@@ -6,6 +6,18 @@ import (
 	"sync"
 )
 
+// Scopes are named "resource:action" (plugins use "plugin:<name>:action").
+// When checking whether a token's scopes grant the scope a route requires,
+// precedence is, from broadest to narrowest:
+//
+//	"*"             grants every scope
+//	"tickets:*"     grants every scope that starts with "tickets:"
+//	"tickets:read"  grants only "tickets:read"
+//
+// Actions never imply one another: "tickets:write" does not grant
+// "tickets:read" and "tickets:delete" does not grant "tickets:write". Scopes
+// only narrow what the token's user may do; they never add permissions.
+
 // ScopeDefinition defines an API token scope
 type ScopeDefinition struct {
 	Scope       string `json:"scope"`
@@ -59,18 +71,133 @@ func init() {
 		Description: "Add articles and replies",
 		Category:    "core",
 	})
+	RegisterScope(&ScopeDefinition{
+		Scope:       "articles:delete",
+		Description: "Delete ticket articles",
+		Category:    "core",
+		AgentOnly:   true,
+	})
 	RegisterScope(&ScopeDefinition{
 		Scope:       "users:read",
 		Description: "View user information",
 		Category:    "core",
 		AgentOnly:   true,
 	})
+	RegisterScope(&ScopeDefinition{
+		Scope:       "users:write",
+		Description: "Create, update and delete users",
+		Category:    "core",
+		AgentOnly:   true,
+	})
+	RegisterScope(&ScopeDefinition{
+		Scope:       "profile:read",
+		Description: "View your own user profile",
+		Category:    "core",
+	})
+	RegisterScope(&ScopeDefinition{
+		Scope:       "groups:read",
+		Description: "View groups",
+		Category:    "core",
+		AgentOnly:   true,
+	})
 	RegisterScope(&ScopeDefinition{
 		Scope:       "queues:read",
 		Description: "View queue information",
 		Category:    "core",
 		AgentOnly:   true,
 	})
+	RegisterScope(&ScopeDefinition{
+		Scope:       "queues:write",
+		Description: "Create, update and delete queues",
+		Category:    "core",
+		AgentOnly:   true,
+	})
+	RegisterScope(&ScopeDefinition{
+		Scope:       "lookups:read",
+		Description: "View priorities, types, states, services and other lookup values",
+		Category:    "core",
+	})
+	RegisterScope(&ScopeDefinition{
+		Scope:       "kb:read",
+		Description: "Read knowledge base articles",
+		Category:    "core",
+	})
+	RegisterScope(&ScopeDefinition{
+		Scope:       "kb:write",
+		Description: "Create, update and delete knowledge base articles",
+		Category:    "core",
+		AgentOnly:   true,
+	})
+	RegisterScope(&ScopeDefinition{
+		Scope:       "approvals:read",
+		Description: "View approval requests",
+		Category:    "core",
+		AgentOnly:   true,
+	})
+	RegisterScope(&ScopeDefinition{
+		Scope:       "approvals:write",
+		Description: "Approve, reject and withdraw approval requests",
+		Category:    "core",
+		AgentOnly:   true,
+	})
+	RegisterScope(&ScopeDefinition{
+		Scope:       "changes:read",
+		Description: "View change plans and the change calendar",
+		Category:    "core",
+		AgentOnly:   true,
+	})
+	RegisterScope(&ScopeDefinition{
+		Scope:       "changes:write",
+		Description: "Plan, reschedule and remove changes",
+		Category:    "core",
+		AgentOnly:   true,
+	})
+	RegisterScope(&ScopeDefinition{
+		Scope:       "calendars:read",
+		Description: "View calendars and appointments, including CalDAV access",
+		Category:    "core",
+		AgentOnly:   true,
+	})
+	RegisterScope(&ScopeDefinition{
+		Scope:       "calendars:write",
+		Description: "Create, update and delete appointments",
+		Category:    "core",
+		AgentOnly:   true,
+	})
+	RegisterScope(&ScopeDefinition{
+		Scope:       "cmdb:read",
+		Description: "Search configuration items and their ticket links",
+		Category:    "core",
+		AgentOnly:   true,
+	})
+	RegisterScope(&ScopeDefinition{
+		Scope:       "cmdb:write",
+		Description: "Create and update configuration items and link them to tickets",
+		Category:    "core",
+		AgentOnly:   true,
+	})
+	RegisterScope(&ScopeDefinition{
+		Scope:       "stats:read",
+		Description: "View agent performance statistics (stats group members)",
+		Category:    "core",
+		AgentOnly:   true,
+	})
+	RegisterScope(&ScopeDefinition{
+		Scope:       "events:read",
+		Description: "Stream real-time ticket and queue events",
+		Category:    "core",
+		AgentOnly:   true,
+	})
+	RegisterScope(&ScopeDefinition{
+		Scope:       "tokens:read",
+		Description: "List your API tokens",
+		Category:    "core",
+	})
+	RegisterScope(&ScopeDefinition{
+		Scope:       "tokens:write",
+		Description: "Create, rotate and revoke your API tokens",
+		Category:    "core",
+	})
 	RegisterScope(&ScopeDefinition{
 		Scope:       "admin:*",
 		Description: "Admin operations",
@@ -134,6 +261,54 @@ func IsValidScope(scope string) bool {
 	return exists
 }
 
+// IsGrantableScope reports whether a scope may be put on a token: "*", a
+// registered scope, or a "resource:*" wildcard covering registered scopes.
+func IsGrantableScope(scope string) bool {
+	if scope == "*" || IsValidScope(scope) {
+		return true
+	}
+	if !strings.HasSuffix(scope, ":*") {
+		return false
+	}
+	scopeRegistry.mu.RLock()
+	defer scopeRegistry.mu.RUnlock()
+	for name := range scopeRegistry.scopes {
+		if name != scope && ScopeGrants(scope, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// ScopeGrants reports whether a token holding granted may use a route that
+// requires required, following the precedence documented above.
+func ScopeGrants(granted, required string) bool {
+	if granted == "*" || granted == required {
+		return true
+	}
+	if prefix, ok := strings.CutSuffix(granted, "*"); ok && strings.HasSuffix(prefix, ":") {
+		return len(required) > len(prefix) && strings.HasPrefix(required, prefix)
+	}
+	return false
+}
+
+// ScopeImpliedBy lists the broader scopes that also grant scope, narrowest
+// first, e.g. "tickets:*" and "*" for "tickets:read".
+func ScopeImpliedBy(scope string) []string {
+	if scope == "*" {
+		return nil
+	}
+	var implied []string
+	parts := strings.Split(scope, ":")
+	for i := len(parts) - 1; i > 0; i-- {
+		wildcard := strings.Join(parts[:i], ":") + ":*"
+		if wildcard != scope {
+			implied = append(implied, wildcard)
+		}
+	}
+	return append(implied, "*")
+}
+
 // IsScopeAllowed checks if a scope is allowed for a given user context
 func IsScopeAllowed(scope string, userRole string, isCustomer bool) bool {
 	scopeRegistry.mu.RLock()
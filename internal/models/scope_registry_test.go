@@ -0,0 +1,82 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestScopeGrants(t *testing.T) {
+	tests := []struct {
+		granted  string
+		required string
+		want     bool
+	}{
+		{"*", "tickets:read", true},
+		{"*", "admin:*", true},
+		{"tickets:read", "tickets:read", true},
+		{"tickets:*", "tickets:delete", true},
+		{"admin:*", "admin:*", true},
+		{"plugin:*", "plugin:calendar:read", true},
+		{"plugin:calendar:*", "plugin:calendar:read", true},
+		// Actions never imply one another
+		{"tickets:write", "tickets:read", false},
+		{"tickets:delete", "tickets:write", false},
+		// Narrower scopes never grant broader ones
+		{"tickets:read", "tickets:*", false},
+		{"plugin:calendar:*", "plugin:*", false},
+		{"tickets:*", "ticketsx:read", false},
+		{"tickets:*", "tickets:", false},
+	}
+
+	for _, tt := range tests {
+		if got := ScopeGrants(tt.granted, tt.required); got != tt.want {
+			t.Errorf("ScopeGrants(%q, %q) = %v, want %v", tt.granted, tt.required, got, tt.want)
+		}
+	}
+}
+
+func TestIsGrantableScope(t *testing.T) {
+	tests := []struct {
+		scope string
+		want  bool
+	}{
+		{"*", true},
+		{"tickets:read", true},
+		{"tickets:*", true},
+		{"admin:*", true},
+		{"kb:*", true},
+		{"bogus:*", false},
+		{"bogus:scope", false},
+		{"tickets", false},
+	}
+
+	for _, tt := range tests {
+		if got := IsGrantableScope(tt.scope); got != tt.want {
+			t.Errorf("IsGrantableScope(%q) = %v, want %v", tt.scope, got, tt.want)
+		}
+	}
+}
+
+func TestScopeImpliedBy(t *testing.T) {
+	tests := []struct {
+		scope string
+		want  []string
+	}{
+		{"tickets:read", []string{"tickets:*", "*"}},
+		{"admin:*", []string{"*"}},
+		{"plugin:calendar:read", []string{"plugin:calendar:*", "plugin:*", "*"}},
+		{"*", nil},
+	}
+
+	for _, tt := range tests {
+		got := ScopeImpliedBy(tt.scope)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ScopeImpliedBy(%q) = %v, want %v", tt.scope, got, tt.want)
+		}
+		for _, broader := range got {
+			if !ScopeGrants(broader, tt.scope) {
+				t.Errorf("ScopeGrants(%q, %q) = false for an implying scope", broader, tt.scope)
+			}
+		}
+	}
+}
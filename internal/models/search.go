@@ -65,6 +65,7 @@ type TicketSearchDocument struct {
 	CreatedAt     time.Time  `json:"created_at"`
 	UpdatedAt     time.Time  `json:"updated_at"`
 	ResolvedAt    *time.Time `json:"resolved_at,omitempty"`
+	Archived      bool       `json:"archived"` // Stored in the archive partition
 
 	// Additional searchable content
 	Messages      []string `json:"messages"`
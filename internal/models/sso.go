@@ -0,0 +1,121 @@
+package models
+
+import "time"
+
+// SSOProtocol is the single sign-on protocol an identity provider speaks.
+type SSOProtocol string
+
+const (
+	SSOProtocolOIDC SSOProtocol = "oidc" // OpenID Connect authorization code flow
+	SSOProtocolSAML SSOProtocol = "saml" // SAML 2.0 Web Browser SSO profile
+)
+
+// IsValid reports whether p is a known protocol.
+func (p SSOProtocol) IsValid() bool {
+	return p == SSOProtocolOIDC || p == SSOProtocolSAML
+}
+
+// SSOFrontend is the interface a provider signs users in to.
+type SSOFrontend string
+
+const (
+	SSOFrontendAgent    SSOFrontend = "agent"
+	SSOFrontendCustomer SSOFrontend = "customer"
+)
+
+// IsValid reports whether f is a known frontend.
+func (f SSOFrontend) IsValid() bool {
+	return f == SSOFrontendAgent || f == SSOFrontendCustomer
+}
+
+// SSOProviderConfig holds the protocol settings and claim names of a
+// provider. It is stored as JSON in sso_provider.config.
+type SSOProviderConfig struct {
+	// OpenID Connect
+	Issuer       string   `json:"issuer,omitempty"`
+	ClientID     string   `json:"client_id,omitempty"`
+	ClientSecret string   `json:"client_secret,omitempty"`
+	Scopes       []string `json:"scopes,omitempty"`
+
+	// SAML 2.0
+	IdPSSOURL      string `json:"idp_sso_url,omitempty"`
+	IdPEntityID    string `json:"idp_entity_id,omitempty"`
+	IdPCertificate string `json:"idp_certificate,omitempty"`
+	// SPEntityID defaults to the provider's metadata URL.
+	SPEntityID string `json:"sp_entity_id,omitempty"`
+
+	// Claim (OIDC) or attribute (SAML) names. An empty UsernameClaim uses
+	// preferred_username for OIDC and the NameID for SAML.
+	UsernameClaim  string `json:"username_claim,omitempty"`
+	EmailClaim     string `json:"email_claim,omitempty"`
+	FirstNameClaim string `json:"first_name_claim,omitempty"`
+	LastNameClaim  string `json:"last_name_claim,omitempty"`
+	GroupsClaim    string `json:"groups_claim,omitempty"`
+
+	// DefaultCustomerID is the company assigned to customer users created on
+	// first login; empty uses the domain of their email address.
+	DefaultCustomerID string `json:"default_customer_id,omitempty"`
+}
+
+// SSOGroupMapping grants a GoatFlow group permission to users whose groups
+// claim contains Value.
+type SSOGroupMapping struct {
+	Value      string `json:"value"`
+	GroupID    int    `json:"group_id"`
+	Permission string `json:"permission"`
+}
+
+// SSOProvider is an OpenID Connect or SAML identity provider (sso_provider
+// table).
+type SSOProvider struct {
+	ID            int               `json:"id"`
+	Name          string            `json:"name"`
+	Protocol      SSOProtocol       `json:"protocol"`
+	Frontend      SSOFrontend       `json:"frontend"`
+	Config        SSOProviderConfig `json:"config"`
+	GroupMappings []SSOGroupMapping `json:"group_mappings"`
+	// JITProvisioning creates unknown users on their first login.
+	JITProvisioning bool      `json:"jit_provisioning"`
+	ValidID         int       `json:"valid_id"`
+	CreateTime      time.Time `json:"create_time"`
+	CreateBy        int       `json:"create_by"`
+	ChangeTime      time.Time `json:"change_time"`
+	ChangeBy        int       `json:"change_by"`
+}
+
+// Redacted returns a copy safe to send to the browser, with a stored client
+// secret replaced by SSOSecretPlaceholder.
+func (p SSOProvider) Redacted() SSOProvider {
+	if p.Config.ClientSecret != "" {
+		p.Config.ClientSecret = SSOSecretPlaceholder
+	}
+	return p
+}
+
+// SSOSecretPlaceholder stands in for a stored client secret in forms and
+// API responses. Submitting it back keeps the stored secret.
+const SSOSecretPlaceholder = "********"
+
+// SSOIdentity is a user as asserted by an identity provider. Subject is
+// the provider's stable identifier for the user; Login only names accounts
+// created on first sign-in.
+type SSOIdentity struct {
+	Subject   string   `json:"subject"`
+	Login     string   `json:"login"`
+	Email     string   `json:"email,omitempty"`
+	FirstName string   `json:"first_name,omitempty"`
+	LastName  string   `json:"last_name,omitempty"`
+	Groups    []string `json:"groups,omitempty"`
+}
+
+// SSOLink binds an account to a provider's subject (sso_identity table).
+// UserID is a users.id for agent providers and a customer_user.id for
+// customer providers.
+type SSOLink struct {
+	ProviderID int       `json:"provider_id"`
+	Subject    string    `json:"subject"`
+	UserID     int       `json:"user_id"`
+	Login      string    `json:"login"`
+	CreateTime time.Time `json:"create_time"`
+	CreateBy   int       `json:"create_by"`
+}
@@ -0,0 +1,132 @@
+package models
+
+import "time"
+
+// SurveyQuestionType is how a survey question is answered.
+type SurveyQuestionType string
+
+const (
+	SurveyQuestionRating SurveyQuestionType = "rating" // 1 (poor) to 5 (excellent)
+	SurveyQuestionNPS    SurveyQuestionType = "nps"    // 0 to 10, how likely the customer recommends you
+	SurveyQuestionChoice SurveyQuestionType = "choice" // One of the question's options
+	SurveyQuestionText   SurveyQuestionType = "text"   // Free text
+)
+
+// IsValid reports whether t is a known question type.
+func (t SurveyQuestionType) IsValid() bool {
+	switch t {
+	case SurveyQuestionRating, SurveyQuestionNPS, SurveyQuestionChoice, SurveyQuestionText:
+		return true
+	}
+	return false
+}
+
+// Scale returns the lowest and highest answer of a rating or NPS question.
+func (t SurveyQuestionType) Scale() (int, int) {
+	if t == SurveyQuestionNPS {
+		return 0, 10
+	}
+	return 1, 5
+}
+
+// SurveyQuestion is one question of a survey.
+type SurveyQuestion struct {
+	ID       string             `json:"id"`
+	Type     SurveyQuestionType `json:"type"`
+	Label    string             `json:"label"`
+	Required bool               `json:"required,omitempty"`
+	Options  []string           `json:"options,omitempty"` // Choice questions
+}
+
+// Survey is a satisfaction survey template (survey table).
+type Survey struct {
+	ID          int              `json:"id"`
+	Name        string           `json:"name"`
+	Description string           `json:"description,omitempty"`
+	Questions   []SurveyQuestion `json:"questions"`
+	// QueueIDs restricts the survey to tickets of these queues; empty means
+	// every queue.
+	QueueIDs []int `json:"queue_ids,omitempty"`
+	// SendDelayMinutes is how long a ticket stays closed before the survey
+	// is sent, so quickly reopened tickets are not surveyed.
+	SendDelayMinutes int       `json:"send_delay_minutes"`
+	LinkValidDays    int       `json:"link_valid_days"`
+	EmailSubject     string    `json:"email_subject"`
+	EmailBody        string    `json:"email_body"`
+	ValidID          int       `json:"valid_id"`
+	CreateTime       time.Time `json:"create_time"`
+	CreateBy         int       `json:"create_by"`
+	ChangeTime       time.Time `json:"change_time"`
+	ChangeBy         int       `json:"change_by"`
+}
+
+// Question returns the survey's question with the ID, or nil.
+func (s *Survey) Question(id string) *SurveyQuestion {
+	for i := range s.Questions {
+		if s.Questions[i].ID == id {
+			return &s.Questions[i]
+		}
+	}
+	return nil
+}
+
+// SurveyRequest is a survey sent for a ticket (survey_request table).
+type SurveyRequest struct {
+	ID             int64          `json:"id"`
+	SurveyID       int            `json:"survey_id"`
+	TicketID       int64          `json:"ticket_id"`
+	TicketNumber   string         `json:"ticket_number,omitempty"`
+	CustomerUserID string         `json:"customer_user_id,omitempty"`
+	SentAt         time.Time      `json:"sent_at"`
+	ExpiresAt      time.Time      `json:"expires_at"`
+	AnsweredAt     *time.Time     `json:"answered_at,omitempty"`
+	Answers        []SurveyAnswer `json:"answers,omitempty"`
+}
+
+// SurveyAnswer is the answer to one question (survey_response table).
+type SurveyAnswer struct {
+	QuestionID   string             `json:"question_id"`
+	QuestionType SurveyQuestionType `json:"question_type"`
+	Rating       *int               `json:"rating,omitempty"`
+	Answer       string             `json:"answer,omitempty"`
+}
+
+// SurveyCandidate is a closed ticket a survey may be sent for.
+type SurveyCandidate struct {
+	TicketID       int64
+	TicketNumber   string
+	Title          string
+	QueueID        int
+	CustomerUserID string
+	Email          string // Customer user's email, or the customer user ID if it is an address
+}
+
+// SurveyQuestionStats aggregates the answers to one question.
+type SurveyQuestionStats struct {
+	QuestionID string             `json:"question_id"`
+	Label      string             `json:"label"`
+	Type       SurveyQuestionType `json:"type"`
+	Responses  int                `json:"responses"`
+	// Average is the mean rating of rating and NPS questions.
+	Average *float64 `json:"average,omitempty"`
+	// NPS is the share of promoters (9-10) minus detractors (0-6) in
+	// percent, for NPS questions.
+	NPS *float64 `json:"nps,omitempty"`
+	// Distribution counts each rating or choice.
+	Distribution map[string]int `json:"distribution,omitempty"`
+}
+
+// SurveyStats aggregates the responses to one survey, or to all surveys
+// when SurveyID is 0.
+type SurveyStats struct {
+	SurveyID     int       `json:"survey_id,omitempty"`
+	From         time.Time `json:"from"`
+	To           time.Time `json:"to"`
+	Sent         int       `json:"sent"`
+	Answered     int       `json:"answered"`
+	ResponseRate float64   `json:"response_rate"` // Percent of sent surveys answered
+	// AverageRating is the mean of all rating answers (1-5).
+	AverageRating *float64              `json:"average_rating,omitempty"`
+	NPS           *float64              `json:"nps,omitempty"`
+	Questions     []SurveyQuestionStats `json:"questions,omitempty"`
+}
@@ -0,0 +1,93 @@
+package models
+
+import "time"
+
+// SysconfigSetting is a system configuration setting with its default and
+// effective value. Values are in stored form: booleans are "true" or
+// "false", arrays are JSON.
+type SysconfigSetting struct {
+	ID          int               `json:"id"`
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	Navigation  string            `json:"navigation"`
+	Type        string            `json:"type"`
+	Options     []SysconfigOption `json:"options,omitempty"`
+	Min         *int              `json:"min,omitempty"`
+	Max         *int              `json:"max,omitempty"`
+	Validation  string            `json:"validation,omitempty"`
+	ReadOnly    bool              `json:"read_only"`
+	Required    bool              `json:"required"`
+	Default     string            `json:"default"`
+	Value       string            `json:"value"`
+	TypedValue  interface{}       `json:"typed_value"`
+	Modified    bool              `json:"modified"`
+	// Secret marks password settings, whose values are never returned.
+	Secret     bool       `json:"secret,omitempty"`
+	ChangeTime *time.Time `json:"change_time,omitempty"`
+	ChangeBy   int        `json:"change_by,omitempty"`
+}
+
+// SysconfigOption is an allowed value of a select setting.
+type SysconfigOption struct {
+	Value string `json:"value"`
+	Label string `json:"label"`
+}
+
+// SysconfigDiffEntry is a setting whose effective value differs from its
+// default, or from the last deployment.
+type SysconfigDiffEntry struct {
+	Name    string `json:"name"`
+	Default string `json:"default"`
+	Value   string `json:"value"`
+	// Deployed is the value in the last deployment; nil when the setting
+	// was not part of it.
+	Deployed *string `json:"deployed,omitempty"`
+	// Pending is true when the value changed since the last deployment.
+	Pending bool `json:"pending"`
+}
+
+// SysconfigDeployment is a snapshot of every effective setting value,
+// stored in sysconfig_deployment.
+type SysconfigDeployment struct {
+	ID         int       `json:"id"`
+	Comments   string    `json:"comments"`
+	CreateTime time.Time `json:"create_time"`
+	CreateBy   int       `json:"create_by"`
+	// SettingCount is the number of settings in the snapshot; 0 for
+	// deployments made by OTRS, whose snapshots GoatFlow cannot read.
+	SettingCount int `json:"setting_count"`
+	// Settings is the snapshot, set only when a single deployment is read.
+	Settings map[string]string `json:"settings,omitempty"`
+}
+
+// Kinds of SysconfigSettingChange.
+const (
+	SysconfigChangeAdded   = "added"
+	SysconfigChangeRemoved = "removed"
+	SysconfigChangeChanged = "changed"
+)
+
+// SysconfigSettingChange is a setting that differs between two
+// deployments. Before is nil for added settings and After for removed ones.
+type SysconfigSettingChange struct {
+	Name   string  `json:"name"`
+	Change string  `json:"change"`
+	Before *string `json:"before,omitempty"`
+	After  *string `json:"after,omitempty"`
+}
+
+// SysconfigDeploymentDiff lists what a deployment changed compared with an
+// earlier one, or with the defaults when there is none.
+type SysconfigDeploymentDiff struct {
+	Deployment *SysconfigDeployment `json:"deployment"`
+	// Previous is the deployment compared against; nil for the defaults.
+	Previous *SysconfigDeployment     `json:"previous,omitempty"`
+	Changes  []SysconfigSettingChange `json:"changes"`
+}
+
+// SysconfigRollback is the result of rolling back to a deployment: the
+// new deployment recording the restored state and the settings it changed.
+type SysconfigRollback struct {
+	Deployment *SysconfigDeployment `json:"deployment"`
+	Changed    []*SysconfigSetting  `json:"changed"`
+}
@@ -0,0 +1,83 @@
+package models
+
+import "time"
+
+// Announcement audiences.
+const (
+	AnnouncementAudienceAgent    = "agent"
+	AnnouncementAudienceCustomer = "customer"
+	AnnouncementAudienceAll      = "all"
+)
+
+// Announcement severities, from least to most urgent.
+const (
+	AnnouncementSeverityInfo     = "info"
+	AnnouncementSeverityWarning  = "warning"
+	AnnouncementSeverityCritical = "critical"
+)
+
+// SystemAnnouncement is an ad-hoc banner shown in the agent and/or customer
+// interface. Without a start or stop time it is shown from its creation
+// until it is invalidated.
+type SystemAnnouncement struct {
+	ID         int        `json:"id"`
+	Message    string     `json:"message"`
+	Audience   string     `json:"audience"`
+	Severity   string     `json:"severity"`
+	StartTime  *time.Time `json:"start_time,omitempty"`
+	StopTime   *time.Time `json:"stop_time,omitempty"`
+	ValidID    int        `json:"valid_id"`
+	CreateTime time.Time  `json:"create_time"`
+	CreateBy   int        `json:"create_by"`
+	ChangeTime time.Time  `json:"change_time"`
+	ChangeBy   int        `json:"change_by"`
+}
+
+// ActiveAt reports whether the announcement is shown at t.
+func (a *SystemAnnouncement) ActiveAt(t time.Time) bool {
+	if a.ValidID != 1 {
+		return false
+	}
+	if a.StartTime != nil && t.Before(*a.StartTime) {
+		return false
+	}
+	return a.StopTime == nil || !t.After(*a.StopTime)
+}
+
+// IsCurrentlyActive reports whether the announcement is shown now.
+func (a *SystemAnnouncement) IsCurrentlyActive() bool {
+	return a.ActiveAt(time.Now())
+}
+
+// StartTimeFormatted returns the start time for display, or "" without one.
+func (a *SystemAnnouncement) StartTimeFormatted() string {
+	return formatAnnouncementTime(a.StartTime, "2006-01-02 15:04")
+}
+
+// StopTimeFormatted returns the stop time for display, or "" without one.
+func (a *SystemAnnouncement) StopTimeFormatted() string {
+	return formatAnnouncementTime(a.StopTime, "2006-01-02 15:04")
+}
+
+// StartTimeISO returns the start time in RFC 3339 for scripts, or "".
+func (a *SystemAnnouncement) StartTimeISO() string {
+	return formatAnnouncementTime(a.StartTime, time.RFC3339)
+}
+
+// StopTimeISO returns the stop time in RFC 3339 for scripts, or "".
+func (a *SystemAnnouncement) StopTimeISO() string {
+	return formatAnnouncementTime(a.StopTime, time.RFC3339)
+}
+
+func formatAnnouncementTime(t *time.Time, layout string) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(layout)
+}
+
+// ShownTo reports whether the announcement is meant for the audience
+// ("agent" or "customer").
+func (a *SystemAnnouncement) ShownTo(audience string) bool {
+	return a.Audience == AnnouncementAudienceAll || a.Audience == audience
+}
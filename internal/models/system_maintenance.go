@@ -89,3 +89,23 @@ func (m *SystemMaintenance) ShowsLoginMessage() bool {
 func (m *SystemMaintenance) IsValid() bool {
 	return m.ValidID == 1
 }
+
+// MaintenanceWindow is the public view of a maintenance window, without the
+// admin comments.
+type MaintenanceWindow struct {
+	ID      int       `json:"id"`
+	Start   time.Time `json:"start"`
+	Stop    time.Time `json:"stop"`
+	Message string    `json:"message,omitempty"`
+}
+
+// MaintenanceStatus describes the maintenance state of the system for
+// status pages and banners.
+type MaintenanceStatus struct {
+	// Maintenance is true while a maintenance window is active; only
+	// admins can sign in then.
+	Maintenance   bool                  `json:"maintenance"`
+	Active        *MaintenanceWindow    `json:"active,omitempty"`
+	Upcoming      *MaintenanceWindow    `json:"upcoming,omitempty"`
+	Announcements []*SystemAnnouncement `json:"announcements"`
+}
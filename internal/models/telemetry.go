@@ -0,0 +1,64 @@
+package models
+
+import "time"
+
+// TelemetryUsage is the usage of one route or plugin function on one day,
+// as stored by opt-in telemetry.
+type TelemetryUsage struct {
+	Date       time.Time
+	Kind       string
+	Name       string
+	Calls      int64
+	Errors     int64
+	DurationMs int64
+}
+
+// TelemetryItem is the usage of a route or plugin function over a period.
+type TelemetryItem struct {
+	Name          string  `json:"name"`
+	Calls         int64   `json:"calls"`
+	Errors        int64   `json:"errors"`
+	ErrorRate     float64 `json:"error_rate"`
+	AvgDurationMs float64 `json:"avg_duration_ms"`
+}
+
+// TelemetryDay is the total usage of one day.
+type TelemetryDay struct {
+	Date           string `json:"date"`
+	Requests       int64  `json:"requests"`
+	RequestErrors  int64  `json:"request_errors"`
+	PluginCalls    int64  `json:"plugin_calls"`
+	PluginFailures int64  `json:"plugin_failures"`
+}
+
+// TelemetryTotals sums the usage of a period. Error rates are percentages.
+type TelemetryTotals struct {
+	Requests        int64   `json:"requests"`
+	RequestErrors   int64   `json:"request_errors"`
+	ErrorRate       float64 `json:"error_rate"`
+	PluginCalls     int64   `json:"plugin_calls"`
+	PluginFailures  int64   `json:"plugin_failures"`
+	PluginErrorRate float64 `json:"plugin_error_rate"`
+}
+
+// TelemetryInsights is the usage shown on the System Insights page.
+type TelemetryInsights struct {
+	From     string          `json:"from"`
+	To       string          `json:"to"`
+	Totals   TelemetryTotals `json:"totals"`
+	Daily    []TelemetryDay  `json:"daily"`
+	Features []TelemetryItem `json:"features"`
+	Plugins  []TelemetryItem `json:"plugins"`
+}
+
+// TelemetryReport is the anonymous report submitted to the telemetry
+// endpoint for one day. It identifies the installation only by a random ID.
+type TelemetryReport struct {
+	InstallID string          `json:"install_id"`
+	Version   string          `json:"version"`
+	GoVersion string          `json:"go_version"`
+	Date      string          `json:"date"`
+	Totals    TelemetryTotals `json:"totals"`
+	Features  []TelemetryItem `json:"features"`
+	Plugins   []TelemetryItem `json:"plugins"`
+}
@@ -192,6 +192,7 @@ type TicketListRequest struct {
 	QueueID             *uint   `json:"queue_id,omitempty" form:"queue_id"`
 	StateID             *uint   `json:"state_id,omitempty" form:"state_id"`
 	PriorityID          *uint   `json:"priority_id,omitempty" form:"priority_id"`
+	TypeID              *uint   `json:"type_id,omitempty" form:"type_id"`
 	CustomerID          *uint   `json:"customer_id,omitempty" form:"customer_id"`
 	OwnerID             *uint   `json:"owner_id,omitempty" form:"owner_id"`
 	Search              string  `json:"search,omitempty" form:"search"`
@@ -0,0 +1,77 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// Archive run states.
+const (
+	ArchiveRunRunning = "running"
+	ArchiveRunSuccess = "success"
+	ArchiveRunFailed  = "failed"
+)
+
+// ArchivePolicy archives tickets in one of StateTypes (ticket state type
+// names such as "closed") that have not changed for ArchiveAfterDays.
+// Archived tickets drop out of default lists and searches. With
+// MoveArticles their article content moves to the archive tables, where the
+// ticket views do not read it until the ticket is restored, and with
+// PurgeAfterDays they are deleted that long after they were archived.
+type ArchivePolicy struct {
+	ID               int       `json:"id"`
+	Name             string    `json:"name"`
+	StateTypes       []string  `json:"state_types"`
+	QueueID          *int      `json:"queue_id,omitempty"`
+	ArchiveAfterDays int       `json:"archive_after_days"`
+	MoveArticles     bool      `json:"move_articles"`
+	PurgeAfterDays   int       `json:"purge_after_days"`
+	ValidID          int       `json:"valid_id"`
+	CreateTime       time.Time `json:"create_time"`
+	CreateBy         int       `json:"create_by"`
+	ChangeTime       time.Time `json:"change_time"`
+	ChangeBy         int       `json:"change_by"`
+}
+
+// StateTypesString returns the state types as stored, comma separated.
+func (p *ArchivePolicy) StateTypesString() string {
+	return strings.Join(p.StateTypes, ",")
+}
+
+// ParseArchiveStateTypes splits a comma separated list of state type names.
+func ParseArchiveStateTypes(s string) []string {
+	var types []string
+	for _, t := range strings.Split(s, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			types = append(types, t)
+		}
+	}
+	return types
+}
+
+// ArchiveRun records one application of the archive policies.
+type ArchiveRun struct {
+	ID              int        `json:"id"`
+	PolicyID        *int       `json:"policy_id,omitempty"`
+	DryRun          bool       `json:"dry_run"`
+	Status          string     `json:"status"`
+	TicketsArchived int        `json:"tickets_archived"`
+	ArticlesMoved   int        `json:"articles_moved"`
+	TicketsPurged   int        `json:"tickets_purged"`
+	Error           string     `json:"error,omitempty"`
+	StartedAt       time.Time  `json:"started_at"`
+	FinishedAt      *time.Time `json:"finished_at,omitempty"`
+	CreateBy        int        `json:"create_by"`
+}
+
+// TicketArchive describes an archived ticket.
+type TicketArchive struct {
+	TicketID      int       `json:"ticket_id"`
+	TicketNumber  string    `json:"ticket_number"`
+	Title         string    `json:"title"`
+	PolicyID      *int      `json:"policy_id,omitempty"`
+	RunID         *int      `json:"run_id,omitempty"`
+	ArticlesMoved int       `json:"articles_moved"`
+	ArchivedAt    time.Time `json:"archived_at"`
+	ArchivedBy    int       `json:"archived_by"`
+}
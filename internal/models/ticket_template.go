@@ -23,6 +23,19 @@ type TicketTemplate struct {
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
 
+	// Ticket attributes pre-filled in the creation form; zero leaves the
+	// form's default.
+	PriorityID  int    `json:"priority_id,omitempty"`
+	StateID     int    `json:"state_id,omitempty"`
+	ServiceID   int    `json:"service_id,omitempty"`
+	ContentType string `json:"content_type,omitempty"` // Of the body: text/plain or text/html
+
+	// Dynamic field values by field name
+	DynamicFields map[string]string `json:"dynamic_fields,omitempty"`
+
+	// Groups whose members may use the template; empty for every agent
+	GroupIDs []int `json:"group_ids,omitempty"`
+
 	// Template variables that can be replaced
 	Variables []TemplateVariable `json:"variables"`
 
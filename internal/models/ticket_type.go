@@ -0,0 +1,87 @@
+package models
+
+import "time"
+
+// TicketType classifies tickets, for example as incidents, service requests
+// or changes.
+type TicketType struct {
+	ID          int       `json:"id"`
+	Name        string    `json:"name"`
+	ValidID     int       `json:"valid_id"`
+	CreateTime  time.Time `json:"create_time"`
+	CreateBy    int       `json:"create_by"`
+	ChangeTime  time.Time `json:"change_time"`
+	ChangeBy    int       `json:"change_by"`
+	TicketCount int       `json:"ticket_count"`
+}
+
+// TicketTypeWorkflow is the handling configured for a ticket type: the
+// defaults applied when a ticket of the type is created, the dynamic fields
+// shown for it and the state changes it allows.
+type TicketTypeWorkflow struct {
+	TypeID            int                    `json:"type_id"`
+	DefaultQueueID    *int                   `json:"default_queue_id"`
+	DefaultPriorityID *int                   `json:"default_priority_id"`
+	DefaultSLAID      *int                   `json:"default_sla_id"`
+	DynamicFields     []TicketTypeField      `json:"dynamic_fields"`
+	Transitions       []TicketTypeTransition `json:"transitions"`
+	ChangeTime        *time.Time             `json:"change_time,omitempty"`
+	ChangeBy          int                    `json:"change_by,omitempty"`
+}
+
+// TicketTypeField is a dynamic field shown for tickets of a type.
+type TicketTypeField struct {
+	FieldID  int  `json:"field_id"`
+	Required bool `json:"required"`
+}
+
+// TicketTypeTransition allows tickets of a type to move from one state to
+// another.
+type TicketTypeTransition struct {
+	FromStateID int `json:"from_state_id"`
+	ToStateID   int `json:"to_state_id"`
+}
+
+// AllowsTransition reports whether a ticket may move from one state to
+// another. A workflow without transitions allows every change, and keeping
+// the current state is always allowed.
+func (w *TicketTypeWorkflow) AllowsTransition(fromStateID, toStateID int) bool {
+	if w == nil || len(w.Transitions) == 0 || fromStateID == toStateID {
+		return true
+	}
+	for _, t := range w.Transitions {
+		if t.FromStateID == fromStateID && t.ToStateID == toStateID {
+			return true
+		}
+	}
+	return false
+}
+
+// NextStates returns the states a ticket in fromStateID may move to, or nil
+// when the workflow does not restrict state changes.
+func (w *TicketTypeWorkflow) NextStates(fromStateID int) []int {
+	if w == nil || len(w.Transitions) == 0 {
+		return nil
+	}
+	next := []int{fromStateID}
+	for _, t := range w.Transitions {
+		if t.FromStateID == fromStateID && t.ToStateID != fromStateID {
+			next = append(next, t.ToStateID)
+		}
+	}
+	return next
+}
+
+// ShowsField reports whether the dynamic field belongs to the type's field
+// set. A workflow without a field set shows every field.
+func (w *TicketTypeWorkflow) ShowsField(fieldID int) bool {
+	if w == nil || len(w.DynamicFields) == 0 {
+		return true
+	}
+	for _, f := range w.DynamicFields {
+		if f.FieldID == fieldID {
+			return true
+		}
+	}
+	return false
+}
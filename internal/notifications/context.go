@@ -19,9 +19,10 @@ func BuildRenderContext(ctx context.Context, db *sql.DB, customerLogin string, a
 
 	if strings.TrimSpace(customerLogin) != "" {
 		// Match on login OR email since customer_user_id could contain either
-		var first, last sql.NullString
-		if err := db.QueryRowContext(ctx, database.ConvertPlaceholders(`SELECT first_name, last_name FROM customer_user WHERE login = ? OR email = ?`), customerLogin, customerLogin).Scan(&first, &last); err == nil {
+		var first, last, customerID sql.NullString
+		if err := db.QueryRowContext(ctx, database.ConvertPlaceholders(`SELECT first_name, last_name, customer_id FROM customer_user WHERE login = ? OR email = ?`), customerLogin, customerLogin).Scan(&first, &last, &customerID); err == nil {
 			rc.CustomerFullName = strings.TrimSpace(strings.TrimSpace(first.String + " " + last.String))
+			rc.CustomerID = strings.TrimSpace(customerID.String)
 		}
 		if rc.CustomerFullName == "" {
 			rc.CustomerFullName = strings.TrimSpace(customerLogin)
@@ -12,6 +12,10 @@ type RenderContext struct {
 	CustomerFullName string
 	AgentFirstName   string
 	AgentLastName    string
+
+	// CustomerID is the customer company whose branding frames the email;
+	// empty uses the global branding.
+	CustomerID string
 }
 
 // ApplyBranding stitches salutation and signature around the base body and expands placeholders.
@@ -27,6 +31,25 @@ func ApplyBranding(base string, baseIsHTML bool, identity *QueueIdentity, ctx *R
 	)
 }
 
+// ApplyEmailFrame adds the branding's email header above and footer below
+// a composed body.
+func ApplyEmailFrame(body string, header, footer string) string {
+	if strings.TrimSpace(header) == "" && strings.TrimSpace(footer) == "" {
+		return body
+	}
+	frame := func(text string) *Snippet {
+		if strings.TrimSpace(text) == "" {
+			return nil
+		}
+		contentType := "text/plain"
+		if utils.IsHTML(text) {
+			contentType = "text/html"
+		}
+		return &Snippet{Text: text, ContentType: contentType}
+	}
+	return composeBody(body, utils.IsHTML(body), frame(header), frame(footer))
+}
+
 func composeBody(base string, baseIsHTML bool, salutation, signature *Snippet) string {
 	trimmed := strings.TrimSpace(base)
 	finalIsHTML := baseIsHTML || snippetIsHTML(salutation) || snippetIsHTML(signature)
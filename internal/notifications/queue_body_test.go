@@ -62,3 +62,22 @@ func TestApplyBrandingInterpolatesPlaceholders(t *testing.T) {
 		t.Fatalf("expected agent name substitution: %s", body)
 	}
 }
+
+func TestApplyEmailFrame(t *testing.T) {
+	if body := ApplyEmailFrame("Hello", "", " "); body != "Hello" {
+		t.Fatalf("expected body without frame unchanged, got %q", body)
+	}
+
+	body := ApplyEmailFrame("Thanks for reaching out.", "ACME Support", "ACME Inc. · 1 Main St")
+	if body != "ACME Support\n\nThanks for reaching out.\n\nACME Inc. · 1 Main St" {
+		t.Fatalf("unexpected plain text frame: %q", body)
+	}
+
+	body = ApplyEmailFrame("<p>Your ticket was updated.</p>", `<img src="https://acme.example/logo.png">`, "Bye & thanks")
+	if !strings.HasPrefix(body, `<img src="https://acme.example/logo.png">`) {
+		t.Fatalf("expected HTML header first: %s", body)
+	}
+	if !strings.HasSuffix(body, "<p>Bye &amp; thanks</p>") {
+		t.Fatalf("expected plain text footer escaped in HTML body: %s", body)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	"github.com/goatkit/goatflow/internal/config"
+	"github.com/goatkit/goatflow/internal/sysconfig"
 )
 
 // EmailBranding bundles outbound email metadata for queue-based messages.
@@ -32,6 +33,16 @@ func PrepareQueueEmail(ctx context.Context, db *sql.DB, queueID int, baseBody st
 		finalBody = ApplyBranding(baseBody, baseIsHTML, identity, renderCtx)
 	}
 
+	if db != nil {
+		var customerID string
+		if renderCtx != nil {
+			customerID = renderCtx.CustomerID
+		}
+		if brand, brandErr := sysconfig.LoadBrandingConfig(db, customerID); brandErr == nil {
+			finalBody = ApplyEmailFrame(finalBody, brand.EmailHeader, brand.EmailFooter)
+		}
+	}
+
 	envelope := EnvelopeAddress(identity, fallbackEnvelope)
 	header := HeaderAddress(identity, fallbackHeader)
 	domain := DomainFromAddress(envelope)
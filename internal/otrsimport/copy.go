@@ -0,0 +1,749 @@
+package otrsimport
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// mapChunk is the number of IDs looked up in otrs_import_map at once.
+const mapChunk = 500
+
+// maxCached is the size above which the ID map cache of an entity is
+// dropped after a batch; tickets and articles would not fit in memory.
+const maxCached = 100000
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// table is the source table of an entity and what is copied from it.
+type table struct {
+	source string
+	// columns exist in both tables; the ID is never copied.
+	columns []string
+	// binary are target columns that store bytes rather than text.
+	binary     map[string]bool
+	changeTime bool
+}
+
+// lookupTable translates the IDs of a lookup table by name.
+type lookupTable struct {
+	names   map[int64]string
+	targets map[string]int64
+}
+
+// state is the progress of an entity in otrs_import_state.
+type state struct {
+	lastID    int64
+	watermark string
+}
+
+func (im *Importer) importEntity(ctx context.Context, e *entity, er *EntityReport) error {
+	t, err := im.describe(ctx, e)
+	if err != nil {
+		return err
+	}
+	if t == nil {
+		er.note("no %s table in the source", strings.Join(e.tables, " or "))
+		return nil
+	}
+	if e.link {
+		rows, err := im.readRows(ctx, "SELECT * FROM "+quoteIdent(im.srcDialect, t.source))
+		if err != nil {
+			return err
+		}
+		for start := 0; start < len(rows); start += im.opts.BatchSize {
+			batch := rows[start:min(start+im.opts.BatchSize, len(rows))]
+			if err := im.copyBatch(ctx, e, t, er, batch, nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	st, err := im.loadState(ctx, e.name)
+	if err != nil {
+		return err
+	}
+	if im.opts.Rescan {
+		st = state{}
+	}
+	var mark string
+	if t.changeTime {
+		if mark, err = im.changeMark(ctx, t); err != nil {
+			return err
+		}
+	}
+	// Rows imported before that changed since the last run
+	if t.changeTime && st.lastID > 0 && st.watermark != "" {
+		since, err := time.Parse(time.RFC3339Nano, st.watermark)
+		if err != nil {
+			return fmt.Errorf("parse watermark %q: %w", st.watermark, err)
+		}
+		if err := im.pass(ctx, e, t, er, "id <= ? AND change_time > ?", []any{st.lastID, im.timeArg(since)}, nil); err != nil {
+			return err
+		}
+	}
+	if err := im.pass(ctx, e, t, er, "", nil, &st); err != nil {
+		return err
+	}
+	if im.opts.DryRun || mark == "" {
+		return nil
+	}
+	st.watermark = mark
+	return im.saveState(ctx, im.dst, e.name, st)
+}
+
+// describe returns the table of an entity, or nil when the source has none.
+func (im *Importer) describe(ctx context.Context, e *entity) (*table, error) {
+	t := &table{binary: map[string]bool{}}
+	for _, name := range e.tables {
+		if tableExists(ctx, im.src, im.srcDialect, name) {
+			t.source = name
+			break
+		}
+	}
+	if t.source == "" {
+		return nil, nil
+	}
+	srcCols, err := columnTypes(ctx, im.src, im.srcDialect, t.source)
+	if err != nil {
+		return nil, err
+	}
+	dstCols, err := columnTypes(ctx, im.dst, im.dstDialect, e.target)
+	if err != nil {
+		return nil, err
+	}
+	targets := map[string]*sql.ColumnType{}
+	for _, c := range dstCols {
+		targets[strings.ToLower(c.Name())] = c
+	}
+	for _, c := range srcCols {
+		name := strings.ToLower(c.Name())
+		if name == "change_time" {
+			t.changeTime = true
+		}
+		target, ok := targets[name]
+		if !ok || name == "id" {
+			continue
+		}
+		t.columns = append(t.columns, name)
+		typ := strings.ToUpper(target.DatabaseTypeName())
+		if strings.Contains(typ, "BLOB") || strings.Contains(typ, "BYTEA") || strings.Contains(typ, "BINARY") {
+			t.binary[name] = true
+		}
+	}
+	return t, nil
+}
+
+// changeMark returns the newest change time in the source table; changes
+// after it are picked up by the next run.
+func (im *Importer) changeMark(ctx context.Context, t *table) (string, error) {
+	var mark sql.NullTime
+	err := im.src.QueryRowContext(ctx, "SELECT change_time FROM "+quoteIdent(im.srcDialect, t.source)+
+		" ORDER BY change_time DESC LIMIT 1").Scan(&mark)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return "", fmt.Errorf("read change time of %s: %w", t.source, err)
+	}
+	if !mark.Valid {
+		return "", nil
+	}
+	return mark.Time.Format(time.RFC3339Nano), nil
+}
+
+// pass copies the source rows matching where in batches. With a
+// checkpoint only rows after its last ID are read and each batch advances
+// it.
+func (im *Importer) pass(ctx context.Context, e *entity, t *table, er *EntityReport, where string, args []any, checkpoint *state) error {
+	var cursor int64
+	if checkpoint != nil {
+		cursor = checkpoint.lastID
+	}
+	if where != "" {
+		where += " AND "
+	}
+	query := fmt.Sprintf("SELECT * FROM %s WHERE %sid > ? ORDER BY id LIMIT %d",
+		quoteIdent(im.srcDialect, t.source), where, im.opts.BatchSize)
+	query = rebind(im.srcDialect, query)
+	for {
+		rows, err := im.readRows(ctx, query, append(args[:len(args):len(args)], cursor)...)
+		if err != nil {
+			return err
+		}
+		if len(rows) == 0 {
+			return nil
+		}
+		cursor, _ = toInt64(rows[len(rows)-1]["id"])
+		if checkpoint != nil {
+			checkpoint.lastID = cursor
+		}
+		if err := im.copyBatch(ctx, e, t, er, rows, checkpoint); err != nil {
+			return err
+		}
+		if len(rows) < im.opts.BatchSize {
+			return nil
+		}
+	}
+}
+
+// readRows reads source rows keyed by lower case column name.
+func (im *Importer) readRows(ctx context.Context, query string, args ...any) ([]map[string]any, error) {
+	rows, err := im.src.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("read source: %w", err)
+	}
+	defer rows.Close()
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	var result []map[string]any
+	for rows.Next() {
+		values := make([]any, len(columns))
+		dest := make([]any, len(columns))
+		for i := range values {
+			dest[i] = &values[i]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+		row := make(map[string]any, len(columns))
+		for i, c := range columns {
+			row[strings.ToLower(c)] = values[i]
+		}
+		result = append(result, row)
+	}
+	return result, rows.Err()
+}
+
+// copyBatch copies rows in one transaction together with their ID map
+// entries and the checkpoint.
+func (im *Importer) copyBatch(ctx context.Context, e *entity, t *table, er *EntityReport, rows []map[string]any, checkpoint *state) error {
+	defer func() {
+		if im.opts.Progress != nil {
+			im.opts.Progress(*er)
+		}
+	}()
+	if im.opts.DryRun {
+		return im.copyRows(ctx, im.dst, e, t, er, rows)
+	}
+
+	tx, err := im.dst.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+	if err := im.copyRows(ctx, tx, e, t, er, rows); err != nil {
+		return err
+	}
+	if checkpoint != nil {
+		if err := im.saveState(ctx, tx, e.name, *checkpoint); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	for name, ids := range im.ids {
+		if len(ids) > maxCached {
+			delete(im.ids, name)
+		}
+	}
+	return nil
+}
+
+func (im *Importer) copyRows(ctx context.Context, q execer, e *entity, t *table, er *EntityReport, rows []map[string]any) error {
+	if err := im.prefetch(ctx, q, e, rows); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		er.Scanned++
+		id, _ := toInt64(row["id"])
+		label := fmt.Sprintf("%s %d", t.source, id)
+		if e.link {
+			label = t.source
+		}
+
+		values, problem, err := im.translate(ctx, q, e, t, row)
+		if err != nil {
+			return err
+		}
+		if problem != "" {
+			er.skip("%s: %s", label, problem)
+			continue
+		}
+
+		if m, ok := im.ids[e.name][id]; ok && !e.link {
+			if !m.created {
+				er.Matched++
+				continue
+			}
+			if !im.opts.DryRun {
+				if err := im.update(ctx, q, e, t, m.target, values); err != nil {
+					return fmt.Errorf("update %s: %w", label, err)
+				}
+			}
+			er.Updated++
+			continue
+		}
+
+		existing, err := im.findByKey(ctx, q, e, t, values)
+		if err != nil {
+			return err
+		}
+		switch {
+		case existing != 0 && e.keyConflict:
+			er.skip("%s: %s already exists in GoatFlow", label, keyString(e, t, values))
+			continue
+		case existing != 0:
+			er.Matched++
+			if !e.link {
+				if err := im.remember(ctx, q, e.name, id, mapping{target: existing}); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		target, err := im.insert(ctx, q, e, t, values)
+		if err != nil {
+			return fmt.Errorf("insert %s: %w", label, err)
+		}
+		er.Created++
+		if !e.link {
+			if err := im.remember(ctx, q, e.name, id, mapping{target: target, created: true}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// translate returns the values of the copied columns with references
+// translated to GoatFlow IDs, or why the row cannot be imported.
+func (im *Importer) translate(ctx context.Context, q execer, e *entity, t *table, row map[string]any) ([]any, string, error) {
+	values := make([]any, len(t.columns))
+	for i, col := range t.columns {
+		v := row[col]
+		if b, ok := v.([]byte); ok && !t.binary[col] {
+			v = string(b)
+		}
+		r, isRef := e.refOf(col)
+		if !isRef || v == nil {
+			values[i] = v
+			continue
+		}
+		srcID, ok := toInt64(v)
+		if !ok {
+			return nil, fmt.Sprintf("%s %v is not an ID", col, v), nil
+		}
+		target, problem, err := im.resolve(ctx, q, r, row, srcID)
+		if err != nil {
+			return nil, "", err
+		}
+		switch {
+		case target != 0:
+			values[i] = target
+		case r.fallback != 0:
+			values[i] = r.fallback
+		case r.nullable:
+			values[i] = nil
+		default:
+			return nil, fmt.Sprintf("%s %d %s", col, srcID, problem), nil
+		}
+	}
+	return values, "", nil
+}
+
+// resolve returns the GoatFlow ID for a reference, or 0 and why there is
+// none.
+func (im *Importer) resolve(ctx context.Context, q execer, r ref, row map[string]any, srcID int64) (int64, string, error) {
+	entity := r.entity
+	if r.object {
+		fieldID, _ := toInt64(row["field_id"])
+		objectType, err := im.fieldObject(ctx, fieldID)
+		if err != nil {
+			return 0, "", err
+		}
+		if entity = objectEntities[objectType]; entity == "" {
+			return 0, fmt.Sprintf("belongs to a %s, which is not imported", objectType), nil
+		}
+	}
+	if entity != "" {
+		if m, ok := im.ids[entity][srcID]; ok {
+			return m.target, "", nil
+		}
+		return 0, "has no imported " + entity + " row", nil
+	}
+
+	lt, err := im.lookup(ctx, q, r.table, r.column)
+	if err != nil {
+		return 0, "", err
+	}
+	name, ok := lt.names[srcID]
+	if !ok {
+		return 0, "has no " + r.table + " row in the source", nil
+	}
+	if target, ok := lt.targets[name]; ok {
+		return target, "", nil
+	}
+	return 0, fmt.Sprintf("has no GoatFlow %s named %q", r.table, name), nil
+}
+
+// prefetch loads the ID map entries a batch needs into the cache.
+func (im *Importer) prefetch(ctx context.Context, q execer, e *entity, rows []map[string]any) error {
+	want := map[string][]int64{}
+	add := func(entity string, v any) {
+		id, ok := toInt64(v)
+		if !ok {
+			return
+		}
+		if _, cached := im.ids[entity][id]; !cached {
+			want[entity] = append(want[entity], id)
+		}
+	}
+	for _, row := range rows {
+		if !e.link {
+			add(e.name, row["id"])
+		}
+		for col, v := range row {
+			r, ok := e.refOf(col)
+			switch {
+			case !ok:
+			case r.entity != "":
+				add(r.entity, v)
+			case r.object:
+				fieldID, _ := toInt64(row["field_id"])
+				objectType, err := im.fieldObject(ctx, fieldID)
+				if err != nil {
+					return err
+				}
+				if entity := objectEntities[objectType]; entity != "" {
+					add(entity, v)
+				}
+			}
+		}
+	}
+	for entity, ids := range want {
+		if err := im.loadMap(ctx, q, entity, ids); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (im *Importer) loadMap(ctx context.Context, q execer, entity string, ids []int64) error {
+	cache := im.ids[entity]
+	if cache == nil {
+		cache = map[int64]mapping{}
+		im.ids[entity] = cache
+	}
+	for start := 0; start < len(ids); start += mapChunk {
+		chunk := ids[start:min(start+mapChunk, len(ids))]
+		args := []any{im.opts.Source, entity}
+		for _, id := range chunk {
+			args = append(args, id)
+		}
+		query := rebind(im.dstDialect, "SELECT source_id, target_id, created FROM otrs_import_map WHERE source = ? AND entity = ? AND source_id IN (?"+
+			strings.Repeat(", ?", len(chunk)-1)+")")
+		rows, err := q.QueryContext(ctx, query, args...)
+		if err != nil {
+			return fmt.Errorf("read ID map: %w", err)
+		}
+		for rows.Next() {
+			var source, target, created int64
+			if err := rows.Scan(&source, &target, &created); err != nil {
+				rows.Close()
+				return err
+			}
+			cache[source] = mapping{target: target, created: created == 1}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// remember records where a source row went.
+func (im *Importer) remember(ctx context.Context, q execer, entity string, source int64, m mapping) error {
+	if im.ids[entity] == nil {
+		im.ids[entity] = map[int64]mapping{}
+	}
+	im.ids[entity][source] = m
+	if im.opts.DryRun {
+		return nil
+	}
+	created := 0
+	if m.created {
+		created = 1
+	}
+	_, err := q.ExecContext(ctx, rebind(im.dstDialect,
+		"INSERT INTO otrs_import_map (source, entity, source_id, target_id, created, imported_at) VALUES (?, ?, ?, ?, ?, ?)"),
+		im.opts.Source, entity, source, m.target, created, time.Now())
+	if err != nil {
+		return fmt.Errorf("write ID map: %w", err)
+	}
+	return nil
+}
+
+func (im *Importer) loadState(ctx context.Context, entity string) (state, error) {
+	var st state
+	var watermark sql.NullString
+	err := im.dst.QueryRowContext(ctx, rebind(im.dstDialect,
+		"SELECT last_id, watermark FROM otrs_import_state WHERE source = ? AND entity = ?"),
+		im.opts.Source, entity).Scan(&st.lastID, &watermark)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return st, fmt.Errorf("read import state: %w", err)
+	}
+	st.watermark = watermark.String
+	return st, nil
+}
+
+func (im *Importer) saveState(ctx context.Context, q execer, entity string, st state) error {
+	var n int
+	err := q.QueryRowContext(ctx, rebind(im.dstDialect,
+		"SELECT COUNT(*) FROM otrs_import_state WHERE source = ? AND entity = ?"), im.opts.Source, entity).Scan(&n)
+	if err != nil {
+		return fmt.Errorf("read import state: %w", err)
+	}
+	watermark := sql.NullString{String: st.watermark, Valid: st.watermark != ""}
+	if n > 0 {
+		_, err = q.ExecContext(ctx, rebind(im.dstDialect,
+			"UPDATE otrs_import_state SET last_id = ?, watermark = ?, updated_at = ? WHERE source = ? AND entity = ?"),
+			st.lastID, watermark, time.Now(), im.opts.Source, entity)
+	} else {
+		_, err = q.ExecContext(ctx, rebind(im.dstDialect,
+			"INSERT INTO otrs_import_state (source, entity, last_id, watermark, updated_at) VALUES (?, ?, ?, ?, ?)"),
+			im.opts.Source, entity, st.lastID, watermark, time.Now())
+	}
+	if err != nil {
+		return fmt.Errorf("write import state: %w", err)
+	}
+	return nil
+}
+
+// lookup loads a lookup table of both databases.
+func (im *Importer) lookup(ctx context.Context, q execer, tableName, column string) (*lookupTable, error) {
+	key := tableName + "." + column
+	if lt, ok := im.lookups[key]; ok {
+		return lt, nil
+	}
+	lt := &lookupTable{names: map[int64]string{}, targets: map[string]int64{}}
+	query := fmt.Sprintf("SELECT id, %s FROM %s", quoteIdent(im.srcDialect, column), quoteIdent(im.srcDialect, tableName))
+	if tableExists(ctx, im.src, im.srcDialect, tableName) {
+		if err := scanLookup(ctx, im.src, query, func(id int64, name string) { lt.names[id] = name }); err != nil {
+			return nil, fmt.Errorf("read source %s: %w", tableName, err)
+		}
+	}
+	query = fmt.Sprintf("SELECT id, %s FROM %s", quoteIdent(im.dstDialect, column), quoteIdent(im.dstDialect, tableName))
+	if err := scanLookup(ctx, q, query, func(id int64, name string) { lt.targets[name] = id }); err != nil {
+		return nil, fmt.Errorf("read %s: %w", tableName, err)
+	}
+	im.lookups[key] = lt
+	return lt, nil
+}
+
+func scanLookup(ctx context.Context, q execer, query string, fn func(id int64, name string)) error {
+	rows, err := q.QueryContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id int64
+		var name sql.NullString
+		if err := rows.Scan(&id, &name); err != nil {
+			return err
+		}
+		fn(id, name.String)
+	}
+	return rows.Err()
+}
+
+// fieldObject returns the object type of a source dynamic field.
+func (im *Importer) fieldObject(ctx context.Context, fieldID int64) (string, error) {
+	if im.fieldObjects == nil {
+		objects := map[int64]string{}
+		err := scanLookup(ctx, im.src, "SELECT id, object_type FROM dynamic_field", func(id int64, objectType string) {
+			objects[id] = objectType
+		})
+		if err != nil {
+			return "", fmt.Errorf("read source dynamic fields: %w", err)
+		}
+		im.fieldObjects = objects
+	}
+	return im.fieldObjects[fieldID], nil
+}
+
+// findByKey returns the ID of the GoatFlow row with the key of values, or
+// 1 for an existing link row.
+func (im *Importer) findByKey(ctx context.Context, q execer, e *entity, t *table, values []any) (int64, error) {
+	if len(e.key) == 0 {
+		return 0, nil
+	}
+	var conds []string
+	var args []any
+	for _, k := range e.key {
+		i := columnIndex(t, k)
+		if i < 0 {
+			return 0, nil
+		}
+		conds = append(conds, quoteIdent(im.dstDialect, k)+" = ?")
+		args = append(args, values[i])
+	}
+	selected := "id"
+	if e.link {
+		selected = "1"
+	}
+	query := rebind(im.dstDialect, fmt.Sprintf("SELECT %s FROM %s WHERE %s", selected,
+		quoteIdent(im.dstDialect, e.target), strings.Join(conds, " AND ")))
+	var id int64
+	err := q.QueryRowContext(ctx, query, args...).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("match %s: %w", e.target, err)
+	}
+	return id, nil
+}
+
+// insert creates a GoatFlow row and returns its ID. A dry run returns
+// negative placeholder IDs instead.
+func (im *Importer) insert(ctx context.Context, q execer, e *entity, t *table, values []any) (int64, error) {
+	if im.opts.DryRun {
+		im.placeholder--
+		return im.placeholder, nil
+	}
+	cols := make([]string, len(t.columns))
+	for i, c := range t.columns {
+		cols[i] = quoteIdent(im.dstDialect, c)
+	}
+	query := rebind(im.dstDialect, fmt.Sprintf("INSERT INTO %s (%s) VALUES (?%s)", quoteIdent(im.dstDialect, e.target),
+		strings.Join(cols, ", "), strings.Repeat(", ?", len(cols)-1)))
+	if e.link {
+		_, err := q.ExecContext(ctx, query, values...)
+		return 0, err
+	}
+	if im.dstDialect == "postgres" {
+		var id int64
+		err := q.QueryRowContext(ctx, query+" RETURNING id", values...).Scan(&id)
+		return id, err
+	}
+	res, err := q.ExecContext(ctx, query, values...)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
+
+func (im *Importer) update(ctx context.Context, q execer, e *entity, t *table, id int64, values []any) error {
+	sets := make([]string, len(t.columns))
+	for i, c := range t.columns {
+		sets[i] = quoteIdent(im.dstDialect, c) + " = ?"
+	}
+	query := rebind(im.dstDialect, fmt.Sprintf("UPDATE %s SET %s WHERE id = ?",
+		quoteIdent(im.dstDialect, e.target), strings.Join(sets, ", ")))
+	_, err := q.ExecContext(ctx, query, append(values, id)...)
+	return err
+}
+
+// timeArg binds a source change time for comparison.
+func (im *Importer) timeArg(t time.Time) any {
+	if im.srcDialect == "sqlite" {
+		return t
+	}
+	return t.UTC().Format("2006-01-02 15:04:05.999999")
+}
+
+func keyString(e *entity, t *table, values []any) string {
+	parts := make([]string, 0, len(e.key))
+	for _, k := range e.key {
+		if i := columnIndex(t, k); i >= 0 {
+			parts = append(parts, fmt.Sprintf("%s %v", k, values[i]))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+func columnIndex(t *table, column string) int {
+	for i, c := range t.columns {
+		if c == column {
+			return i
+		}
+	}
+	return -1
+}
+
+func tableExists(ctx context.Context, db *sql.DB, dialect, name string) bool {
+	rows, err := db.QueryContext(ctx, "SELECT * FROM "+quoteIdent(dialect, name)+" WHERE 1 = 0")
+	if err != nil {
+		return false
+	}
+	_ = rows.Close()
+	return true
+}
+
+func columnTypes(ctx context.Context, db *sql.DB, dialect, name string) ([]*sql.ColumnType, error) {
+	rows, err := db.QueryContext(ctx, "SELECT * FROM "+quoteIdent(dialect, name)+" WHERE 1 = 0")
+	if err != nil {
+		return nil, fmt.Errorf("read columns of %s: %w", name, err)
+	}
+	defer rows.Close()
+	return rows.ColumnTypes()
+}
+
+// quoteIdent quotes a table or column name.
+func quoteIdent(dialect, name string) string {
+	if dialect == "mysql" {
+		return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+	}
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// rebind numbers the ? placeholders of a query for PostgreSQL.
+func rebind(dialect, query string) string {
+	if dialect != "postgres" {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func toInt64(v any) (int64, bool) {
+	switch n := v.(type) {
+	case int64:
+		return n, true
+	case int32:
+		return int64(n), true
+	case int:
+		return int64(n), true
+	case float64:
+		return int64(n), true
+	case []byte:
+		i, err := strconv.ParseInt(string(n), 10, 64)
+		return i, err == nil
+	case string:
+		i, err := strconv.ParseInt(n, 10, 64)
+		return i, err == nil
+	}
+	return 0, false
+}
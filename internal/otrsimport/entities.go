@@ -0,0 +1,129 @@
+package otrsimport
+
+// entity describes how the rows of one source table are imported.
+type entity struct {
+	// name identifies the entity in options, reports and the ID map.
+	name string
+	// tables are the source table names, the first one that exists is
+	// read; Znuny 7 renamed groups to permission_groups.
+	tables []string
+	target string
+	// key are the columns that find an existing GoatFlow row for a source
+	// row. Without a key every source row is created.
+	key []string
+	// keyConflict makes a key match an error rather than the same object:
+	// an existing ticket number belongs to a different ticket.
+	keyConflict bool
+	// link tables have no ID; a row is copied unless an equal one exists.
+	link bool
+	refs map[string]ref
+}
+
+// ref translates a column holding the ID of another row.
+type ref struct {
+	// entity whose ID map translates the column.
+	entity string
+	// table and column of a lookup table whose rows are matched by name
+	// instead.
+	table, column string
+	// object translates a dynamic field value's object ID through the
+	// entity of the field's object type.
+	object bool
+	// fallback is used for a value without counterpart; 0 skips the row
+	// unless the column is nullable.
+	fallback int64
+	nullable bool
+}
+
+// commonRefs apply to every table that has these columns.
+var commonRefs = map[string]ref{
+	"create_by": {entity: "users", fallback: 1},
+	"change_by": {entity: "users", fallback: 1},
+	"valid_id":  {table: "valid", column: "name", fallback: 1},
+}
+
+// objectEntities are the dynamic field object types that are imported.
+var objectEntities = map[string]string{
+	"Ticket":  "tickets",
+	"Article": "articles",
+}
+
+// entities are imported in this order, so that references point to rows
+// imported before.
+var entities = []*entity{
+	{name: "groups", tables: []string{"groups", "permission_groups"}, target: "groups", key: []string{"name"}},
+	{name: "roles", tables: []string{"roles"}, target: "roles", key: []string{"name"}},
+	{name: "users", tables: []string{"users"}, target: "users", key: []string{"login"}},
+	{name: "group_user", tables: []string{"group_user"}, target: "group_user", link: true,
+		key: []string{"user_id", "group_id", "permission_key"},
+		refs: map[string]ref{
+			"user_id":  {entity: "users"},
+			"group_id": {entity: "groups"},
+		}},
+	{name: "role_user", tables: []string{"role_user"}, target: "role_user", link: true,
+		key: []string{"user_id", "role_id"},
+		refs: map[string]ref{
+			"user_id": {entity: "users"},
+			"role_id": {entity: "roles"},
+		}},
+	{name: "group_role", tables: []string{"group_role"}, target: "group_role", link: true,
+		key: []string{"role_id", "group_id", "permission_key"},
+		refs: map[string]ref{
+			"role_id":  {entity: "roles"},
+			"group_id": {entity: "groups"},
+		}},
+	{name: "queues", tables: []string{"queue"}, target: "queue", key: []string{"name"},
+		refs: map[string]ref{
+			"group_id":          {entity: "groups"},
+			"system_address_id": {table: "system_address", column: "value0", fallback: 1},
+			"salutation_id":     {table: "salutation", column: "name", fallback: 1},
+			"signature_id":      {table: "signature", column: "name", fallback: 1},
+			"follow_up_id":      {table: "follow_up_possible", column: "name", fallback: 1},
+		}},
+	{name: "dynamic_fields", tables: []string{"dynamic_field"}, target: "dynamic_field", key: []string{"name"}},
+	{name: "tickets", tables: []string{"ticket"}, target: "ticket", key: []string{"tn"}, keyConflict: true,
+		refs: map[string]ref{
+			"queue_id":            {entity: "queues"},
+			"user_id":             {entity: "users", fallback: 1},
+			"responsible_user_id": {entity: "users", fallback: 1},
+			"ticket_lock_id":      {table: "ticket_lock_type", column: "name"},
+			"ticket_priority_id":  {table: "ticket_priority", column: "name"},
+			"ticket_state_id":     {table: "ticket_state", column: "name"},
+			"type_id":             {table: "ticket_type", column: "name", nullable: true},
+			"service_id":          {table: "service", column: "name", nullable: true},
+			"sla_id":              {table: "sla", column: "name", nullable: true},
+		}},
+	{name: "articles", tables: []string{"article"}, target: "article",
+		refs: map[string]ref{
+			"ticket_id":                {entity: "tickets"},
+			"article_sender_type_id":   {table: "article_sender_type", column: "name"},
+			"communication_channel_id": {table: "communication_channel", column: "name"},
+		}},
+	{name: "article_contents", tables: []string{"article_data_mime"}, target: "article_data_mime",
+		refs: map[string]ref{"article_id": {entity: "articles"}}},
+	{name: "attachments", tables: []string{"article_data_mime_attachment"}, target: "article_data_mime_attachment",
+		refs: map[string]ref{"article_id": {entity: "articles"}}},
+	{name: "dynamic_field_values", tables: []string{"dynamic_field_value"}, target: "dynamic_field_value",
+		refs: map[string]ref{
+			"field_id":  {entity: "dynamic_fields"},
+			"object_id": {object: true},
+		}},
+}
+
+func entityByName(name string) *entity {
+	for _, e := range entities {
+		if e.name == name {
+			return e
+		}
+	}
+	return nil
+}
+
+// refOf returns how a column is translated.
+func (e *entity) refOf(column string) (ref, bool) {
+	if r, ok := e.refs[column]; ok {
+		return r, true
+	}
+	r, ok := commonRefs[column]
+	return r, ok
+}
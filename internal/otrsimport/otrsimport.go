@@ -0,0 +1,226 @@
+// Package otrsimport copies the data of a running OTRS 6+ or Znuny
+// installation into GoatFlow.
+//
+// Both systems share the OTRS schema, so rows are copied column by column;
+// what changes are the IDs. Every imported row is recorded in
+// otrs_import_map with the GoatFlow ID it got, and lookup values (states,
+// priorities, sender types, ...) are matched by name. Rows are read in ID
+// order in batches, and each batch commits together with its checkpoint in
+// otrs_import_state, so an interrupted import resumes where it stopped and
+// a later run only copies what is new or changed since.
+package otrsimport
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// DefaultBatchSize is the number of source rows copied per transaction.
+const DefaultBatchSize = 500
+
+// maxIssues is the number of issues kept per entity in a report.
+const maxIssues = 20
+
+var (
+	// ErrUnsupportedSource is returned for databases that are not OTRS 6+
+	// or Znuny.
+	ErrUnsupportedSource = errors.New("otrsimport: source is not an OTRS 6+ or Znuny database")
+	// ErrUnknownEntity is returned for an entity name that is not imported.
+	ErrUnknownEntity = errors.New("otrsimport: unknown entity")
+)
+
+// Options configure an import.
+type Options struct {
+	// Source names the source system in the ID map, so that several systems
+	// can be imported into one GoatFlow. Defaults to "otrs".
+	Source       string
+	SourceDriver string
+	TargetDriver string
+	BatchSize    int
+	// DryRun reads everything and reports what would be imported without
+	// writing to GoatFlow.
+	DryRun bool
+	// Rescan processes all source rows again instead of resuming. Rows
+	// imported before are updated rather than duplicated.
+	Rescan bool
+	// Entities limits the import to these entities; empty imports all.
+	Entities []string
+	// Progress is called after every batch.
+	Progress func(EntityReport)
+}
+
+// EntityReport counts what happened to the rows of one entity.
+type EntityReport struct {
+	Entity  string `json:"entity"`
+	Scanned int    `json:"scanned"`
+	// Created rows are new in GoatFlow, Matched rows already existed there
+	// (by name or login) and were left alone, Updated rows were imported
+	// before and changed in the source since.
+	Created    int      `json:"created"`
+	Matched    int      `json:"matched"`
+	Updated    int      `json:"updated"`
+	Skipped    int      `json:"skipped"`
+	IssueCount int      `json:"issue_count"`
+	Issues     []string `json:"issues,omitempty"`
+}
+
+// skip counts a row that was not imported and records why.
+func (r *EntityReport) skip(format string, args ...any) {
+	r.Skipped++
+	r.note(format, args...)
+}
+
+// note records an issue.
+func (r *EntityReport) note(format string, args ...any) {
+	r.IssueCount++
+	if len(r.Issues) < maxIssues {
+		r.Issues = append(r.Issues, fmt.Sprintf(format, args...))
+	}
+}
+
+// Report is the outcome of an import.
+type Report struct {
+	Source     string          `json:"source"`
+	DryRun     bool            `json:"dry_run"`
+	StartedAt  time.Time       `json:"started_at"`
+	FinishedAt time.Time       `json:"finished_at"`
+	Entities   []*EntityReport `json:"entities"`
+}
+
+// WriteText writes the report as a table followed by the issues.
+func (r *Report) WriteText(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "ENTITY\tSCANNED\tCREATED\tMATCHED\tUPDATED\tSKIPPED\t")
+	for _, e := range r.Entities {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\t%d\t\n", e.Entity, e.Scanned, e.Created, e.Matched, e.Updated, e.Skipped)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	for _, e := range r.Entities {
+		if e.IssueCount == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "\n%s: %d issue(s)\n", e.Entity, e.IssueCount)
+		for _, issue := range e.Issues {
+			fmt.Fprintf(w, "  - %s\n", issue)
+		}
+		if more := e.IssueCount - len(e.Issues); more > 0 {
+			fmt.Fprintf(w, "  ... and %d more\n", more)
+		}
+	}
+	return nil
+}
+
+// mapping is the GoatFlow row an imported source row went to.
+type mapping struct {
+	target  int64
+	created bool
+}
+
+// Importer copies one source system into GoatFlow.
+type Importer struct {
+	src, dst   *sql.DB
+	opts       Options
+	srcDialect string
+	dstDialect string
+
+	// ids caches otrs_import_map per entity.
+	ids map[string]map[int64]mapping
+	// lookups are the lookup tables by "table.column".
+	lookups map[string]*lookupTable
+	// fieldObjects are the object types of the source dynamic fields.
+	fieldObjects map[int64]string
+	// placeholder numbers the rows a dry run would create.
+	placeholder int64
+}
+
+// New creates an importer from src into dst.
+func New(src, dst *sql.DB, opts Options) *Importer {
+	if opts.Source == "" {
+		opts.Source = "otrs"
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = DefaultBatchSize
+	}
+	return &Importer{
+		src:        src,
+		dst:        dst,
+		opts:       opts,
+		srcDialect: dialect(opts.SourceDriver),
+		dstDialect: dialect(opts.TargetDriver),
+		ids:        map[string]map[int64]mapping{},
+		lookups:    map[string]*lookupTable{},
+	}
+}
+
+// Run imports the selected entities in dependency order. The report is
+// returned with the counts so far when the import fails.
+func (im *Importer) Run(ctx context.Context) (*Report, error) {
+	selected, err := selectEntities(im.opts.Entities)
+	if err != nil {
+		return nil, err
+	}
+	report := &Report{Source: im.opts.Source, DryRun: im.opts.DryRun, StartedAt: time.Now()}
+	defer func() { report.FinishedAt = time.Now() }()
+
+	if !tableExists(ctx, im.src, im.srcDialect, "article_data_mime") {
+		return report, ErrUnsupportedSource
+	}
+	for _, e := range selected {
+		er := &EntityReport{Entity: e.name}
+		report.Entities = append(report.Entities, er)
+		if err := im.importEntity(ctx, e, er); err != nil {
+			return report, fmt.Errorf("import %s: %w", e.name, err)
+		}
+	}
+	return report, nil
+}
+
+// EntityNames returns the importable entities in import order.
+func EntityNames() []string {
+	names := make([]string, len(entities))
+	for i, e := range entities {
+		names[i] = e.name
+	}
+	return names
+}
+
+func selectEntities(names []string) ([]*entity, error) {
+	if len(names) == 0 {
+		return entities, nil
+	}
+	want := map[string]bool{}
+	for _, name := range names {
+		if entityByName(name) == nil {
+			return nil, fmt.Errorf("%w %q, expected one of %s", ErrUnknownEntity, name, strings.Join(EntityNames(), ", "))
+		}
+		want[name] = true
+	}
+	var selected []*entity
+	for _, e := range entities {
+		if want[e.name] {
+			selected = append(selected, e)
+		}
+	}
+	return selected, nil
+}
+
+// dialect returns the database family of a driver name.
+func dialect(driver string) string {
+	switch strings.ToLower(driver) {
+	case "mysql", "mariadb":
+		return "mysql"
+	case "postgres", "postgresql", "pgx":
+		return "postgres"
+	case "sqlite", "sqlite3":
+		return "sqlite"
+	}
+	return strings.ToLower(driver)
+}
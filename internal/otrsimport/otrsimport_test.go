@@ -0,0 +1,200 @@
+package otrsimport
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/testutil"
+)
+
+var created = time.Date(2026, 9, 1, 8, 0, 0, 0, time.UTC)
+
+func exec(t *testing.T, db *sql.DB, query string, args ...any) {
+	t.Helper()
+	_, err := db.Exec(query, args...)
+	require.NoError(t, err, query)
+}
+
+func count(t *testing.T, db *sql.DB, query string, args ...any) int {
+	t.Helper()
+	var n int
+	require.NoError(t, db.QueryRow(query, args...).Scan(&n), query)
+	return n
+}
+
+// seed adds the admin and ticket states to the lookup rows the migrations
+// create, so that the same state has different IDs in both systems.
+func seed(t *testing.T, db *sql.DB, states map[int]string) {
+	exec(t, db, "INSERT INTO users (id, login, pw, first_name, last_name, valid_id, create_time, create_by, change_time, change_by) VALUES (1, 'root@localhost', 'x', 'Admin', 'OTRS', 1, ?, 1, ?, 1)", created, created)
+	for id, name := range states {
+		exec(t, db, "INSERT INTO ticket_state (id, name, type_id, valid_id, create_time, create_by, change_time, change_by) VALUES (?, ?, 1, 1, ?, 1, ?, 1)",
+			id, name, created, created)
+	}
+}
+
+func insertQueue(t *testing.T, db *sql.DB, id int, name string, groupID int) {
+	exec(t, db, `INSERT INTO queue (id, name, group_id, system_address_id, salutation_id, signature_id, follow_up_id, follow_up_lock, valid_id,
+		create_time, create_by, change_time, change_by) VALUES (?, ?, ?, 1, 1, 1, 1, 0, 1, ?, 1, ?, 1)`, id, name, groupID, created, created)
+}
+
+func insertTicket(t *testing.T, db *sql.DB, id int, tn string, queueID, userID, stateID, priorityID int) {
+	exec(t, db, `INSERT INTO ticket (id, tn, title, queue_id, ticket_lock_id, user_id, responsible_user_id, ticket_priority_id, ticket_state_id,
+		timeout, until_time, escalation_time, escalation_update_time, escalation_response_time, escalation_solution_time,
+		create_time, create_by, change_time, change_by) VALUES (?, ?, 'Printer broken', ?, 1, ?, ?, ?, ?, 0, 0, 0, 0, 0, 0, ?, 1, ?, 1)`,
+		id, tn, queueID, userID, userID, priorityID, stateID, created, created)
+}
+
+func insertArticle(t *testing.T, db *sql.DB, id, ticketID int, body string) {
+	exec(t, db, `INSERT INTO article (id, ticket_id, article_sender_type_id, communication_channel_id, is_visible_for_customer,
+		create_time, create_by, change_time, change_by) VALUES (?, ?, 3, 1, 1, ?, 2, ?, 2)`, id, ticketID, created, created)
+	exec(t, db, `INSERT INTO article_data_mime (id, article_id, a_subject, a_body, incoming_time, create_time, create_by, change_time, change_by)
+		VALUES (?, ?, 'Printer', ?, 0, ?, 2, ?, 2)`, id+100, id, body, created, created)
+}
+
+// otrsSource returns an OTRS database with one agent in a support group,
+// a queue, two tickets (one in a state GoatFlow lacks) and a third whose
+// number is taken in GoatFlow.
+func otrsSource(t *testing.T) *sql.DB {
+	db := testutil.MigratedDB(t)
+	seed(t, db, map[int]string{7: "in progress", 8: "merged"})
+	exec(t, db, "INSERT INTO users (id, login, pw, first_name, last_name, valid_id, create_time, create_by, change_time, change_by) VALUES (2, 'agent1', 'x', 'Ann', 'Agent', 1, ?, 1, ?, 1)", created, created)
+	exec(t, db, "INSERT INTO groups (id, name, valid_id, create_time, create_by, change_time, change_by) VALUES (7, 'support', 1, ?, 1, ?, 1)", created, created)
+	exec(t, db, "INSERT INTO group_user (user_id, group_id, permission_key, create_time, create_by, change_time, change_by) VALUES (2, 7, 'rw', ?, 1, ?, 1)", created, created)
+	insertQueue(t, db, 9, "Support", 7)
+	insertTicket(t, db, 10, "2026090110000010", 9, 2, 7, 3)
+	insertTicket(t, db, 11, "2026090110000011", 9, 2, 8, 3)
+	insertTicket(t, db, 12, "1000", 9, 2, 2, 3)
+	insertArticle(t, db, 20, 10, "It does not print")
+	exec(t, db, `INSERT INTO article_data_mime_attachment (id, article_id, filename, content_type, content, create_time, create_by, change_time, change_by)
+		VALUES (40, 20, 'error.png', 'image/png', ?, ?, 2, ?, 2)`, []byte{0x89, 'P', 'N', 'G', 0}, created, created)
+	exec(t, db, `INSERT INTO dynamic_field (id, name, label, field_order, field_type, object_type, config, valid_id, create_time, create_by, change_time, change_by)
+		VALUES (2, 'Asset', 'Asset', 1, 'Text', 'Ticket', ?, 1, ?, 1, ?, 1)`, []byte("---\nDefaultValue: ''\n"), created, created)
+	exec(t, db, "INSERT INTO dynamic_field_value (id, field_id, object_id, value_text) VALUES (50, 2, 10, 'PRN-7')")
+	return db
+}
+
+// goatflowTarget returns a GoatFlow database with its own ID for the "in
+// progress" state and a ticket numbered 1000.
+func goatflowTarget(t *testing.T) *sql.DB {
+	db := testutil.MigratedDB(t)
+	seed(t, db, map[int]string{9: "in progress"})
+	insertTicket(t, db, 1, "1000", 1, 1, 1, 3)
+	return db
+}
+
+func entityReport(t *testing.T, r *Report, name string) EntityReport {
+	t.Helper()
+	for _, e := range r.Entities {
+		if e.Entity == name {
+			return *e
+		}
+	}
+	t.Fatalf("no report for %s", name)
+	return EntityReport{}
+}
+
+func TestImport(t *testing.T) {
+	ctx := context.Background()
+	src, dst := otrsSource(t), goatflowTarget(t)
+	opts := Options{Source: "otrs6", SourceDriver: "sqlite", TargetDriver: "sqlite", BatchSize: 1}
+
+	// A dry run reports without writing
+	dry := opts
+	dry.DryRun = true
+	report, err := New(src, dst, dry).Run(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, EntityReport{Entity: "users", Scanned: 2, Created: 1, Matched: 1}, entityReport(t, report, "users"))
+	tickets := entityReport(t, report, "tickets")
+	assert.Equal(t, []int{3, 1, 2}, []int{tickets.Scanned, tickets.Created, tickets.Skipped})
+	assert.Equal(t, []string{
+		`ticket 11: ticket_state_id 8 has no GoatFlow ticket_state named "merged"`,
+		"ticket 12: tn 1000 already exists in GoatFlow",
+	}, tickets.Issues)
+	assert.Equal(t, 1, entityReport(t, report, "dynamic_field_values").Created)
+	assert.Equal(t, 1, count(t, dst, "SELECT COUNT(*) FROM ticket"))
+	assert.Zero(t, count(t, dst, "SELECT COUNT(*) FROM otrs_import_map"))
+	assert.Zero(t, count(t, dst, "SELECT COUNT(*) FROM otrs_import_state"))
+
+	var progress int
+	opts.Progress = func(EntityReport) { progress++ }
+	report, err = New(src, dst, opts).Run(ctx)
+	require.NoError(t, err)
+	assert.Positive(t, progress)
+	var text bytes.Buffer
+	require.NoError(t, report.WriteText(&text))
+	assert.Contains(t, text.String(), "tickets: 2 issue(s)")
+	for _, name := range []string{"groups", "group_user", "queues", "tickets", "articles", "article_contents", "attachments", "dynamic_fields", "dynamic_field_values"} {
+		assert.Equal(t, 1, entityReport(t, report, name).Created, name)
+	}
+
+	var agentID, groupID, queueID, queueGroupID int64
+	require.NoError(t, dst.QueryRow("SELECT id FROM users WHERE login = 'agent1'").Scan(&agentID))
+	require.NoError(t, dst.QueryRow("SELECT id FROM groups WHERE name = 'support'").Scan(&groupID))
+	require.NoError(t, dst.QueryRow("SELECT id, group_id FROM queue WHERE name = 'Support'").Scan(&queueID, &queueGroupID))
+	assert.Equal(t, groupID, queueGroupID)
+	var ticketID, ticketQueueID, stateID, priorityID, userID int64
+	require.NoError(t, dst.QueryRow("SELECT id, queue_id, ticket_state_id, ticket_priority_id, user_id FROM ticket WHERE tn = '2026090110000010'").
+		Scan(&ticketID, &ticketQueueID, &stateID, &priorityID, &userID))
+	assert.Equal(t, []int64{queueID, 9, 3, agentID}, []int64{ticketQueueID, stateID, priorityID, userID})
+	assert.Equal(t, 1, count(t, dst, "SELECT COUNT(*) FROM group_user WHERE user_id = ? AND group_id = ? AND permission_key = 'rw'", agentID, groupID))
+
+	var body string
+	var content []byte
+	var senderID, objectID int64
+	require.NoError(t, dst.QueryRow(`SELECT m.a_body, a.article_sender_type_id FROM article a JOIN article_data_mime m ON m.article_id = a.id
+		WHERE a.ticket_id = ?`, ticketID).Scan(&body, &senderID))
+	assert.Equal(t, "It does not print", body)
+	assert.Equal(t, int64(3), senderID)
+	require.NoError(t, dst.QueryRow("SELECT content FROM article_data_mime_attachment WHERE filename = 'error.png'").Scan(&content))
+	assert.Equal(t, []byte{0x89, 'P', 'N', 'G', 0}, content)
+	require.NoError(t, dst.QueryRow("SELECT object_id FROM dynamic_field_value WHERE value_text = 'PRN-7'").Scan(&objectID))
+	assert.Equal(t, ticketID, objectID)
+	assert.Equal(t, 1, count(t, dst, "SELECT COUNT(*) FROM otrs_import_map WHERE source = 'otrs6' AND entity = 'users' AND created = 0"))
+
+	// A re-run resumes after the last imported rows and picks up changes
+	later := created.Add(time.Hour)
+	exec(t, src, "UPDATE ticket SET title = 'Printer on fire', change_time = ? WHERE id = 10", later)
+	insertArticle(t, src, 21, 10, "Now it burns")
+	report, err = New(src, dst, opts).Run(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, EntityReport{Entity: "tickets", Scanned: 1, Updated: 1}, entityReport(t, report, "tickets"))
+	assert.Equal(t, EntityReport{Entity: "articles", Scanned: 1, Created: 1}, entityReport(t, report, "articles"))
+	assert.Equal(t, EntityReport{Entity: "users"}, entityReport(t, report, "users"))
+	assert.Equal(t, 1, count(t, dst, "SELECT COUNT(*) FROM ticket WHERE title = 'Printer on fire'"))
+	assert.Equal(t, 2, count(t, dst, "SELECT COUNT(*) FROM article WHERE ticket_id = ?", ticketID))
+
+	// A rescan goes over everything again without duplicating
+	opts.Rescan = true
+	opts.Entities = []string{"users", "tickets", "group_user"}
+	report, err = New(src, dst, opts).Run(ctx)
+	require.NoError(t, err)
+	assert.Len(t, report.Entities, 3)
+	assert.Equal(t, EntityReport{Entity: "users", Scanned: 2, Matched: 1, Updated: 1}, entityReport(t, report, "users"))
+	assert.Equal(t, 1, entityReport(t, report, "tickets").Updated)
+	assert.Equal(t, EntityReport{Entity: "group_user", Scanned: 1, Matched: 1}, entityReport(t, report, "group_user"))
+	assert.Equal(t, 2, count(t, dst, "SELECT COUNT(*) FROM ticket"))
+
+	_, err = New(src, dst, Options{Entities: []string{"customers"}}).Run(ctx)
+	assert.ErrorIs(t, err, ErrUnknownEntity)
+}
+
+func TestImportUnsupportedSource(t *testing.T) {
+	ctx := context.Background()
+	src, dst := testutil.MigratedDB(t), testutil.MigratedDB(t)
+	exec(t, src, "DROP TABLE article_data_mime")
+	_, err := New(src, dst, Options{SourceDriver: "sqlite", TargetDriver: "sqlite"}).Run(ctx)
+	assert.ErrorIs(t, err, ErrUnsupportedSource)
+}
+
+func TestRebind(t *testing.T) {
+	assert.Equal(t, "SELECT 1 WHERE a = $1 AND b IN ($2, $3)", rebind("postgres", "SELECT 1 WHERE a = ? AND b IN (?, ?)"))
+	assert.Equal(t, "SELECT 1 WHERE a = ?", rebind("mysql", "SELECT 1 WHERE a = ?"))
+	assert.Equal(t, "`groups`", quoteIdent("mysql", "groups"))
+	assert.Equal(t, `"groups"`, quoteIdent("postgres", "groups"))
+}
@@ -0,0 +1,241 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// BreakerPolicy configures the circuit breaker the manager keeps for each
+// plugin. Zero values fall back to the corresponding DefaultBreakerPolicy
+// value.
+type BreakerPolicy struct {
+	// Disabled turns the breaker off; calls always reach the plugin.
+	Disabled bool `json:"disabled"`
+	// Window is the span over which calls and failures are counted.
+	Window time.Duration `json:"window"`
+	// MinCalls is how many calls a window needs before its failure rate
+	// can open the breaker, so a single failure does not.
+	MinCalls int `json:"min_calls"`
+	// FailureRate is the share of failed calls (errors, timeouts, crashes)
+	// in a window that opens the breaker, between 0 and 1.
+	FailureRate float64 `json:"failure_rate"`
+	// OpenFor is how long calls are short-circuited before one probe call
+	// is let through to test whether the plugin recovered.
+	OpenFor time.Duration `json:"open_for"`
+}
+
+// DefaultBreakerPolicy returns the breaker policy applied when none is set.
+func DefaultBreakerPolicy() BreakerPolicy {
+	return BreakerPolicy{
+		Window:      30 * time.Second,
+		MinCalls:    10,
+		FailureRate: 0.5,
+		OpenFor:     30 * time.Second,
+	}
+}
+
+// withDefaults fills zero fields from DefaultBreakerPolicy.
+func (p BreakerPolicy) withDefaults() BreakerPolicy {
+	d := DefaultBreakerPolicy()
+	if p.Window <= 0 {
+		p.Window = d.Window
+	}
+	if p.MinCalls <= 0 {
+		p.MinCalls = d.MinCalls
+	}
+	if p.FailureRate <= 0 || p.FailureRate > 1 {
+		p.FailureRate = d.FailureRate
+	}
+	if p.OpenFor <= 0 {
+		p.OpenFor = d.OpenFor
+	}
+	return p
+}
+
+// BreakerState is the state of a plugin's circuit breaker.
+type BreakerState string
+
+const (
+	// BreakerClosed lets calls through and counts their failures.
+	BreakerClosed BreakerState = "closed"
+	// BreakerOpen rejects calls with a PluginUnavailableError.
+	BreakerOpen BreakerState = "open"
+	// BreakerHalfOpen lets a single probe call through; its outcome closes
+	// or reopens the breaker.
+	BreakerHalfOpen BreakerState = "half_open"
+)
+
+// BreakerStatus reports a plugin's circuit breaker for the plugins API.
+type BreakerStatus struct {
+	State     BreakerState `json:"state"`
+	Calls     int          `json:"calls"`    // Calls in the current window
+	Failures  int          `json:"failures"` // Failed calls in the current window
+	OpenUntil *time.Time   `json:"open_until,omitempty"`
+}
+
+// PluginUnavailableError is returned while a plugin's circuit breaker is
+// open after too many of its calls failed.
+type PluginUnavailableError struct {
+	PluginName   string
+	CallerPlugin string
+	RetryAfter   time.Duration // Until the breaker lets a probe call through
+}
+
+func (e *PluginUnavailableError) Error() string {
+	if e.CallerPlugin != "" {
+		return fmt.Sprintf("plugin %q is unavailable after repeated failures (required by %q), retry in %s",
+			e.PluginName, e.CallerPlugin, e.RetryAfter.Round(time.Second))
+	}
+	return fmt.Sprintf("plugin %q is unavailable after repeated failures, retry in %s",
+		e.PluginName, e.RetryAfter.Round(time.Second))
+}
+
+// breakerProbeRetry is the retry hint given while a probe call is running.
+const breakerProbeRetry = time.Second
+
+type breaker struct {
+	state       BreakerState
+	windowStart time.Time
+	calls       int
+	failures    int
+	openUntil   time.Time
+}
+
+// breakers holds the circuit breakers of all plugins.
+type breakers struct {
+	mu     sync.Mutex
+	policy BreakerPolicy
+	byName map[string]*breaker
+	now    func() time.Time
+}
+
+func newBreakers() *breakers {
+	return &breakers{
+		policy: DefaultBreakerPolicy(),
+		byName: make(map[string]*breaker),
+		now:    time.Now,
+	}
+}
+
+func (bs *breakers) get(name string) *breaker {
+	b, ok := bs.byName[name]
+	if !ok {
+		b = &breaker{state: BreakerClosed, windowStart: bs.now()}
+		bs.byName[name] = b
+	}
+	return b
+}
+
+// allow reports whether a call to name may proceed and, if not, when to
+// retry.
+func (bs *breakers) allow(name string) (time.Duration, bool) {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	if bs.policy.Disabled {
+		return 0, true
+	}
+	b, now := bs.get(name), bs.now()
+	switch b.state {
+	case BreakerOpen:
+		if now.Before(b.openUntil) {
+			return b.openUntil.Sub(now), false
+		}
+		b.state = BreakerHalfOpen // This call is the probe
+		return 0, true
+	case BreakerHalfOpen:
+		return breakerProbeRetry, false
+	}
+	if now.Sub(b.windowStart) >= bs.policy.Window {
+		b.windowStart, b.calls, b.failures = now, 0, 0
+	}
+	return 0, true
+}
+
+// record counts the outcome of a call allow let through.
+func (bs *breakers) record(name string, failed bool) {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	if bs.policy.Disabled {
+		return
+	}
+	b, now := bs.get(name), bs.now()
+	switch b.state {
+	case BreakerHalfOpen:
+		if failed {
+			bs.open(name, b, now, "probe call failed")
+			return
+		}
+		b.state, b.windowStart, b.calls, b.failures = BreakerClosed, now, 0, 0
+		log.Printf("plugin: %s circuit breaker closed, probe call succeeded", name)
+		GetLogBuffer().Log(name, "info", "circuit breaker closed, plugin recovered", nil)
+	case BreakerClosed:
+		b.calls++
+		if failed {
+			b.failures++
+		}
+		if b.calls >= bs.policy.MinCalls && float64(b.failures)/float64(b.calls) >= bs.policy.FailureRate {
+			bs.open(name, b, now, fmt.Sprintf("%d of %d calls failed", b.failures, b.calls))
+		}
+	}
+	// Calls finishing after the breaker opened are not counted
+}
+
+func (bs *breakers) open(name string, b *breaker, now time.Time, reason string) {
+	b.state, b.openUntil = BreakerOpen, now.Add(bs.policy.OpenFor)
+	log.Printf("plugin: %s circuit breaker opened for %s: %s", name, bs.policy.OpenFor, reason)
+	GetLogBuffer().Log(name, "warn", "circuit breaker opened: "+reason, map[string]any{
+		"open_for": bs.policy.OpenFor.String(),
+	})
+}
+
+func (bs *breakers) status(name string) BreakerStatus {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	b, ok := bs.byName[name]
+	if !ok || bs.policy.Disabled {
+		return BreakerStatus{State: BreakerClosed}
+	}
+	st := BreakerStatus{State: b.state, Calls: b.calls, Failures: b.failures}
+	if b.state == BreakerOpen {
+		until := b.openUntil
+		st.OpenUntil = &until
+	}
+	return st
+}
+
+func (bs *breakers) reset(name string) {
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	delete(bs.byName, name)
+}
+
+// SetBreakerPolicy replaces the circuit breaker policy of all plugins.
+func (m *Manager) SetBreakerPolicy(policy BreakerPolicy) {
+	m.breakers.mu.Lock()
+	defer m.breakers.mu.Unlock()
+	if policy.Disabled {
+		m.breakers.policy = BreakerPolicy{Disabled: true}
+		return
+	}
+	m.breakers.policy = policy.withDefaults()
+}
+
+// BreakerStatus returns the state of a plugin's circuit breaker.
+func (m *Manager) BreakerStatus(name string) BreakerStatus {
+	return m.breakers.status(name)
+}
+
+// ResetBreaker closes a plugin's circuit breaker and forgets its failures.
+func (m *Manager) ResetBreaker(name string) {
+	m.breakers.reset(name)
+}
+
+// callFailed reports whether err counts against the plugin's breaker.
+// Calls abandoned by the caller do not.
+func callFailed(ctx context.Context, err error) bool {
+	return err != nil && !errors.Is(ctx.Err(), context.Canceled)
+}
@@ -0,0 +1,121 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+// flakyPlugin fails its calls while failing is set.
+type flakyPlugin struct {
+	failing bool
+	calls   int
+}
+
+func (p *flakyPlugin) GKRegister() GKRegistration { return GKRegistration{Name: "flaky"} }
+
+func (p *flakyPlugin) Init(ctx context.Context, host HostAPI) error { return nil }
+
+func (p *flakyPlugin) Call(ctx context.Context, fn string, args json.RawMessage) (json.RawMessage, error) {
+	p.calls++
+	if p.failing {
+		return nil, context.DeadlineExceeded
+	}
+	return json.RawMessage(`{}`), nil
+}
+
+func (p *flakyPlugin) Shutdown(ctx context.Context) error { return nil }
+
+func TestManagerCircuitBreaker(t *testing.T) {
+	ctx := context.Background()
+	mgr := NewManager(nil)
+	now := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	mgr.breakers.now = func() time.Time { return now }
+	mgr.SetBreakerPolicy(BreakerPolicy{Window: time.Minute, MinCalls: 4, FailureRate: 0.5, OpenFor: 10 * time.Second})
+	flaky := &flakyPlugin{}
+	mgr.plugins["flaky"] = &registeredPlugin{plugin: flaky, enabled: true}
+
+	call := func() error {
+		_, err := mgr.Call(ctx, "flaky", "widget", nil)
+		return err
+	}
+
+	// Two failures in three calls stay below MinCalls
+	for _, failing := range []bool{false, true, true} {
+		flaky.failing = failing
+		_ = call()
+	}
+	if st := mgr.BreakerStatus("flaky"); st.State != BreakerClosed || st.Calls != 3 || st.Failures != 2 {
+		t.Fatalf("expected a closed breaker counting 2 of 3 failures, got %+v", st)
+	}
+
+	// The fourth call reaches the rate and opens the breaker
+	_ = call()
+	st := mgr.BreakerStatus("flaky")
+	if st.State != BreakerOpen || st.OpenUntil == nil || !st.OpenUntil.Equal(now.Add(10*time.Second)) {
+		t.Fatalf("expected an open breaker, got %+v", st)
+	}
+
+	calls := flaky.calls
+	err := call()
+	var unavailable *PluginUnavailableError
+	if !errors.As(err, &unavailable) || unavailable.RetryAfter != 10*time.Second {
+		t.Fatalf("expected a PluginUnavailableError, got %v", err)
+	}
+	if flaky.calls != calls {
+		t.Error("an open breaker must not reach the plugin")
+	}
+	if _, err := mgr.CallFrom(ctx, "dashboard", "flaky", "widget", nil); !errors.As(err, &unavailable) || unavailable.CallerPlugin != "dashboard" {
+		t.Errorf("expected the caller in the error, got %v", err)
+	}
+
+	// A failed probe reopens it
+	now = now.Add(11 * time.Second)
+	if err := call(); errors.As(err, &unavailable) {
+		t.Fatal("expected a probe call after OpenFor")
+	}
+	if st := mgr.BreakerStatus("flaky"); st.State != BreakerOpen {
+		t.Fatalf("expected the failed probe to reopen the breaker, got %+v", st)
+	}
+
+	// A successful probe closes it
+	now = now.Add(11 * time.Second)
+	flaky.failing = false
+	if err := call(); err != nil {
+		t.Fatalf("probe call: %v", err)
+	}
+	if st := mgr.BreakerStatus("flaky"); st.State != BreakerClosed || st.Calls != 0 {
+		t.Fatalf("expected a closed, reset breaker, got %+v", st)
+	}
+
+	// Failures of an earlier window are forgotten
+	flaky.failing = true
+	for i := 0; i < 3; i++ {
+		_ = call()
+	}
+	now = now.Add(2 * time.Minute)
+	_ = call()
+	if st := mgr.BreakerStatus("flaky"); st.State != BreakerClosed || st.Calls != 1 {
+		t.Fatalf("expected a new window, got %+v", st)
+	}
+
+	// Calls abandoned by the caller do not count
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	for i := 0; i < 5; i++ {
+		_, _ = mgr.Call(canceled, "flaky", "widget", nil)
+	}
+	if st := mgr.BreakerStatus("flaky"); st.Failures != 1 {
+		t.Errorf("expected canceled calls not to count, got %+v", st)
+	}
+
+	mgr.SetBreakerPolicy(BreakerPolicy{Disabled: true})
+	for i := 0; i < 10; i++ {
+		_ = call()
+	}
+	if st := mgr.BreakerStatus("flaky"); st.State != BreakerClosed {
+		t.Errorf("expected a disabled breaker to stay closed, got %+v", st)
+	}
+}
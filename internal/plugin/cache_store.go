@@ -0,0 +1,213 @@
+package plugin
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// CacheStore holds the values plugins keep through the CacheGet, CacheSet
+// and CacheDelete host functions. The host namespaces keys per plugin
+// before they reach the store.
+type CacheStore interface {
+	// Get returns the value of key, or false when it is missing or expired.
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	// Set stores value under key. A ttl of 0 keeps it until it is evicted.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Delete removes key. Deleting a missing key is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// Defaults for MemoryCacheStore.
+const (
+	DefaultCacheMaxEntries = 10000
+	DefaultCacheMaxBytes   = 64 << 20
+)
+
+// MemoryCacheStore keeps plugin cache values in process memory. When it
+// holds more than its entry or byte limit, the least recently used values
+// are evicted. Every instance has its own copy, so use RedisCacheStore when
+// several replicas serve the same plugins.
+type MemoryCacheStore struct {
+	maxEntries int
+	maxBytes   int64
+	onEvict    func(key string)
+
+	mu    sync.Mutex
+	lru   *list.List // Front is the most recently used
+	items map[string]*list.Element
+	bytes int64
+}
+
+type memoryCacheEntry struct {
+	key     string
+	value   []byte
+	expires time.Time // Zero for no expiry
+}
+
+// NewMemoryCacheStore creates a store holding at most maxEntries values of
+// together maxBytes. Zero limits take DefaultCacheMaxEntries and
+// DefaultCacheMaxBytes.
+func NewMemoryCacheStore(maxEntries int, maxBytes int64) *MemoryCacheStore {
+	if maxEntries <= 0 {
+		maxEntries = DefaultCacheMaxEntries
+	}
+	if maxBytes <= 0 {
+		maxBytes = DefaultCacheMaxBytes
+	}
+	return &MemoryCacheStore{
+		maxEntries: maxEntries,
+		maxBytes:   maxBytes,
+		lru:        list.New(),
+		items:      make(map[string]*list.Element),
+	}
+}
+
+// OnEvict sets a function called with the key of every value evicted to
+// make room. Expired values are not reported.
+func (s *MemoryCacheStore) OnEvict(fn func(key string)) {
+	s.mu.Lock()
+	s.onEvict = fn
+	s.mu.Unlock()
+}
+
+// Get implements CacheStore.
+func (s *MemoryCacheStore) Get(_ context.Context, key string) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	el, ok := s.items[key]
+	if !ok {
+		return nil, false, nil
+	}
+	e := el.Value.(*memoryCacheEntry)
+	if !e.expires.IsZero() && time.Now().After(e.expires) {
+		s.remove(el)
+		return nil, false, nil
+	}
+	s.lru.MoveToFront(el)
+	return append([]byte(nil), e.value...), true, nil
+}
+
+// Set implements CacheStore.
+func (s *MemoryCacheStore) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	e := &memoryCacheEntry{key: key, value: append([]byte(nil), value...)}
+	if ttl > 0 {
+		e.expires = time.Now().Add(ttl)
+	}
+	size := entrySize(e)
+	if size > s.maxBytes {
+		return errors.New("value exceeds the cache size")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if el, ok := s.items[key]; ok {
+		s.remove(el)
+	}
+	s.items[key] = s.lru.PushFront(e)
+	s.bytes += size
+	s.evict()
+	return nil
+}
+
+// Delete implements CacheStore.
+func (s *MemoryCacheStore) Delete(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if el, ok := s.items[key]; ok {
+		s.remove(el)
+	}
+	return nil
+}
+
+// Len returns the number of stored values and their size in bytes.
+func (s *MemoryCacheStore) Len() (entries int, bytes int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.items), s.bytes
+}
+
+// evict drops expired values, then the least recently used ones, until the
+// store is within its limits. The caller holds s.mu.
+func (s *MemoryCacheStore) evict() {
+	if len(s.items) <= s.maxEntries && s.bytes <= s.maxBytes {
+		return
+	}
+	now := time.Now()
+	for el := s.lru.Back(); el != nil; {
+		prev := el.Prev()
+		if e := el.Value.(*memoryCacheEntry); !e.expires.IsZero() && now.After(e.expires) {
+			s.remove(el)
+		}
+		el = prev
+	}
+	for len(s.items) > s.maxEntries || s.bytes > s.maxBytes {
+		el := s.lru.Back()
+		s.remove(el)
+		if s.onEvict != nil {
+			s.onEvict(el.Value.(*memoryCacheEntry).key)
+		}
+	}
+}
+
+// remove drops el. The caller holds s.mu.
+func (s *MemoryCacheStore) remove(el *list.Element) {
+	e := el.Value.(*memoryCacheEntry)
+	s.lru.Remove(el)
+	delete(s.items, e.key)
+	s.bytes -= entrySize(e)
+}
+
+func entrySize(e *memoryCacheEntry) int64 {
+	return int64(len(e.key) + len(e.value))
+}
+
+// DefaultCacheKeyPrefix starts the keys RedisCacheStore writes unless
+// another prefix is given.
+const DefaultCacheKeyPrefix = "goatflow:plugin_cache:"
+
+// RedisCacheStore keeps plugin cache values in Valkey or Redis, shared by
+// every instance. Values are stored as they are, with the TTL as the key's
+// expiry. Size limits are left to the server's maxmemory policy.
+type RedisCacheStore struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+// NewRedisCacheStore creates a store using client. Keys start with prefix,
+// DefaultCacheKeyPrefix when empty.
+func NewRedisCacheStore(client redis.UniversalClient, prefix string) *RedisCacheStore {
+	if prefix == "" {
+		prefix = DefaultCacheKeyPrefix
+	}
+	return &RedisCacheStore{client: client, prefix: prefix}
+}
+
+// Get implements CacheStore.
+func (s *RedisCacheStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	val, err := s.client.Get(ctx, s.prefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return val, true, nil
+}
+
+// Set implements CacheStore.
+func (s *RedisCacheStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if ttl < 0 {
+		ttl = 0
+	}
+	return s.client.Set(ctx, s.prefix+key, value, ttl).Err()
+}
+
+// Delete implements CacheStore.
+func (s *RedisCacheStore) Delete(ctx context.Context, key string) error {
+	return s.client.Del(ctx, s.prefix+key).Err()
+}
@@ -0,0 +1,114 @@
+package plugin
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// MaxCrashReports is how many crash reports are kept per plugin.
+const MaxCrashReports = 20
+
+// maxCrashStack caps the stack stored with a crash report.
+const maxCrashStack = 16 << 10
+
+// Runtimes reported in crash reports.
+const (
+	CrashRuntimeGo   = "go" // in-process plugins compiled into the host
+	CrashRuntimeWASM = "wasm"
+	CrashRuntimeGRPC = "grpc"
+)
+
+// CrashError is returned when a plugin call ended in a WASM trap or a panic
+// instead of an error returned by the plugin. Runtimes fill in Runtime,
+// Reason and Stack; the manager adds the plugin and function.
+type CrashError struct {
+	Plugin   string
+	Function string
+	Runtime  string
+	Reason   string // e.g. "wasm error: unreachable" or the panic value
+	Stack    string // WASM or Go stack trace, empty when unavailable
+	Err      error  // underlying runtime error, if any
+}
+
+func (e *CrashError) Error() string {
+	if e.Plugin != "" {
+		return fmt.Sprintf("plugin %q crashed in %q: %s", e.Plugin, e.Function, e.Reason)
+	}
+	return "plugin crashed: " + e.Reason
+}
+
+func (e *CrashError) Unwrap() error { return e.Err }
+
+// CrashReport records a plugin crash for the admin plugin page.
+type CrashReport struct {
+	Time     time.Time `json:"time"`
+	Plugin   string    `json:"plugin"`
+	Function string    `json:"function"`
+	Runtime  string    `json:"runtime"`
+	ArgsSize int       `json:"args_size"`
+	Reason   string    `json:"reason"`
+	Stack    string    `json:"stack,omitempty"`
+}
+
+// crashLog keeps the latest crash reports of each plugin, newest first.
+type crashLog struct {
+	mu      sync.Mutex
+	reports map[string][]CrashReport
+}
+
+func newCrashLog() *crashLog {
+	return &crashLog{reports: make(map[string][]CrashReport)}
+}
+
+func (l *crashLog) add(r CrashReport) {
+	if len(r.Stack) > maxCrashStack {
+		r.Stack = r.Stack[:maxCrashStack] + "\n... (truncated)"
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	reports := append([]CrashReport{r}, l.reports[r.Plugin]...)
+	if len(reports) > MaxCrashReports {
+		reports = reports[:MaxCrashReports]
+	}
+	l.reports[r.Plugin] = reports
+}
+
+func (l *crashLog) get(name string) []CrashReport {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]CrashReport(nil), l.reports[name]...)
+}
+
+func (l *crashLog) clear(name string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.reports, name)
+}
+
+// CrashReports returns the latest crash reports of a plugin, newest first.
+func (m *Manager) CrashReports(name string) []CrashReport {
+	return m.crashes.get(name)
+}
+
+// ClearCrashReports forgets the crash reports of a plugin.
+func (m *Manager) ClearCrashReports(name string) {
+	m.crashes.clear(name)
+}
+
+func (m *Manager) recordCrash(crash *CrashError, argsSize int) {
+	m.crashes.add(CrashReport{
+		Time:     time.Now(),
+		Plugin:   crash.Plugin,
+		Function: crash.Function,
+		Runtime:  crash.Runtime,
+		ArgsSize: argsSize,
+		Reason:   crash.Reason,
+		Stack:    crash.Stack,
+	})
+	GetLogBuffer().Log(crash.Plugin, "error", "plugin crashed: "+crash.Reason, map[string]any{
+		"function":  crash.Function,
+		"runtime":   crash.Runtime,
+		"args_size": argsSize,
+	})
+}
@@ -0,0 +1,85 @@
+package plugin_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/goatkit/goatflow/internal/plugin"
+)
+
+// crashingPlugin panics in "panic" and reports a runtime crash in "trap".
+type crashingPlugin struct{}
+
+func (p *crashingPlugin) GKRegister() plugin.GKRegistration {
+	return plugin.GKRegistration{Name: "crashy", Version: "1.0.0"}
+}
+
+func (p *crashingPlugin) Init(ctx context.Context, host plugin.HostAPI) error { return nil }
+
+func (p *crashingPlugin) Call(ctx context.Context, fn string, args json.RawMessage) (json.RawMessage, error) {
+	switch fn {
+	case "panic":
+		panic("index out of range")
+	case "trap":
+		return nil, &plugin.CrashError{Runtime: plugin.CrashRuntimeWASM, Reason: "wasm error: unreachable", Stack: ".$3()"}
+	case "fail":
+		return nil, errors.New("not found")
+	}
+	return json.RawMessage(`{}`), nil
+}
+
+func (p *crashingPlugin) Shutdown(ctx context.Context) error { return nil }
+
+func TestManagerCrashReports(t *testing.T) {
+	ctx := context.Background()
+	mgr := plugin.NewManager(&mockHostAPI{})
+	mgr.SetBreakerPolicy(plugin.BreakerPolicy{Disabled: true}) // Keep calling the crashing plugin
+	if err := mgr.Register(ctx, &crashingPlugin{}); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := mgr.Call(ctx, "crashy", "panic", []byte(`{"id":1}`))
+	var crash *plugin.CrashError
+	if !errors.As(err, &crash) {
+		t.Fatalf("expected a CrashError, got %v", err)
+	}
+	if err.Error() != `plugin "crashy" crashed in "panic": index out of range` {
+		t.Errorf("unexpected message %q", err.Error())
+	}
+
+	if _, err := mgr.CallFrom(ctx, "other", "crashy", "trap", nil); !errors.As(err, &crash) {
+		t.Fatalf("expected a CrashError, got %v", err)
+	}
+	if _, err := mgr.Call(ctx, "crashy", "fail", nil); err == nil {
+		t.Fatal("expected the plugin error")
+	}
+
+	reports := mgr.CrashReports("crashy")
+	if len(reports) != 2 {
+		t.Fatalf("expected 2 crash reports (errors are not crashes), got %d", len(reports))
+	}
+	if reports[0].Function != "trap" || reports[0].Runtime != plugin.CrashRuntimeWASM || reports[0].Stack != ".$3()" {
+		t.Errorf("unexpected newest report %+v", reports[0])
+	}
+	if reports[1].Function != "panic" || reports[1].Runtime != plugin.CrashRuntimeGo || reports[1].ArgsSize != 8 {
+		t.Errorf("unexpected oldest report %+v", reports[1])
+	}
+	if !strings.Contains(reports[1].Stack, "crash_test.go") {
+		t.Errorf("expected the Go stack of the panic, got %q", reports[1].Stack)
+	}
+
+	for i := 0; i < plugin.MaxCrashReports+5; i++ {
+		_, _ = mgr.Call(ctx, "crashy", "trap", nil)
+	}
+	if n := len(mgr.CrashReports("crashy")); n != plugin.MaxCrashReports {
+		t.Errorf("expected reports capped at %d, got %d", plugin.MaxCrashReports, n)
+	}
+
+	mgr.ClearCrashReports("crashy")
+	if n := len(mgr.CrashReports("crashy")); n != 0 {
+		t.Errorf("expected no reports after clearing, got %d", n)
+	}
+}
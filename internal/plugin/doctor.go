@@ -0,0 +1,170 @@
+package plugin
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/goatkit/goatflow/internal/database"
+)
+
+// DoctorIssueKind classifies an inconsistency found by Diagnose.
+type DoctorIssueKind string
+
+const (
+	DoctorLoadFailed    DoctorIssueKind = "load_failed"    // File in the plugins directory that cannot be loaded
+	DoctorNameMismatch  DoctorIssueKind = "name_mismatch"  // File name differs from the manifest name
+	DoctorDuplicate     DoctorIssueKind = "duplicate"      // Two sources provide the same plugin name
+	DoctorOrphanEnabled DoctorIssueKind = "orphan_enabled" // Enabled flag in sysconfig for a missing plugin
+	DoctorOrphanPolicy  DoctorIssueKind = "orphan_policy"  // Resource policy configured for a missing plugin
+	DoctorOrphanSchema  DoctorIssueKind = "orphan_schema"  // Applied schema migrations for a missing plugin
+)
+
+// DoctorFile is a plugin file found in the plugins directory.
+type DoctorFile struct {
+	Path     string
+	Manifest string // Name from the plugin's manifest; empty if loading failed
+	Err      error
+}
+
+// DoctorState is a snapshot of every place plugin state is kept.
+type DoctorState struct {
+	Builtin       []string        // Plugins compiled into the server
+	Files         []DoctorFile    // Plugin files in the plugins directory
+	EnabledFlags  map[string]bool // Plugin::<name>::Enabled entries in sysconfig_modified
+	Policies      []string        // Plugins with a configured resource policy
+	SchemaPlugins []string        // Plugins with rows in plugin_schema_migration
+}
+
+// DoctorIssue is one inconsistency between the plugin stores.
+type DoctorIssue struct {
+	Kind    DoctorIssueKind `json:"kind"`
+	Plugin  string          `json:"plugin"`
+	Detail  string          `json:"detail"`
+	Fixable bool            `json:"fixable"` // Repair can remove it without losing plugin data
+}
+
+// Diagnose reconciles the plugin stores against the plugins the server
+// would register and returns the inconsistencies, sorted by plugin name.
+func Diagnose(state DoctorState) []DoctorIssue {
+	var issues []DoctorIssue
+	known := make(map[string]string) // plugin name -> source
+
+	for _, name := range state.Builtin {
+		known[name] = "built-in"
+	}
+	for _, f := range state.Files {
+		if f.Err != nil {
+			issues = append(issues, DoctorIssue{Kind: DoctorLoadFailed, Plugin: doctorFileName(f.Path),
+				Detail: fmt.Sprintf("%s: %v", f.Path, f.Err)})
+			continue
+		}
+		if src, ok := known[f.Manifest]; ok {
+			issues = append(issues, DoctorIssue{Kind: DoctorDuplicate, Plugin: f.Manifest,
+				Detail: fmt.Sprintf("%s is shadowed by %s", f.Path, src)})
+			continue
+		}
+		known[f.Manifest] = f.Path
+		if base := doctorFileName(f.Path); base != f.Manifest {
+			issues = append(issues, DoctorIssue{Kind: DoctorNameMismatch, Plugin: f.Manifest,
+				Detail: fmt.Sprintf("%s registers as %q; reload and upload replace files by name", f.Path, f.Manifest)})
+		}
+	}
+
+	for name, enabled := range state.EnabledFlags {
+		if _, ok := known[name]; !ok {
+			issues = append(issues, DoctorIssue{Kind: DoctorOrphanEnabled, Plugin: name,
+				Detail: fmt.Sprintf("sysconfig %s = %t for a plugin that is not installed", pluginConfigKey(name), enabled), Fixable: true})
+		}
+	}
+	for _, name := range state.Policies {
+		if _, ok := known[name]; !ok {
+			issues = append(issues, DoctorIssue{Kind: DoctorOrphanPolicy, Plugin: name,
+				Detail: "resource policy configured for a plugin that is not installed; remove it from the server configuration"})
+		}
+	}
+	for _, name := range state.SchemaPlugins {
+		if _, ok := known[name]; !ok {
+			issues = append(issues, DoctorIssue{Kind: DoctorOrphanSchema, Plugin: name,
+				Detail: fmt.Sprintf("schema migrations recorded for a plugin that is not installed; tables with prefix %s may hold its data", SchemaPrefix(name))})
+		}
+	}
+
+	sort.SliceStable(issues, func(i, j int) bool {
+		if issues[i].Plugin != issues[j].Plugin {
+			return issues[i].Plugin < issues[j].Plugin
+		}
+		return issues[i].Kind < issues[j].Kind
+	})
+	return issues
+}
+
+// LoadDoctorDBState reads the enabled flags and schema migration records
+// from the database into state.
+func LoadDoctorDBState(ctx context.Context, db *sql.DB, state *DoctorState) error {
+	rows, err := db.QueryContext(ctx, database.ConvertPlaceholders(
+		`SELECT name, effective_value FROM sysconfig_modified WHERE name LIKE ?`), "Plugin::%::Enabled")
+	if err != nil {
+		return fmt.Errorf("read plugin enabled flags: %w", err)
+	}
+	state.EnabledFlags = make(map[string]bool)
+	for rows.Next() {
+		var key string
+		var value sql.NullString
+		if err := rows.Scan(&key, &value); err != nil {
+			rows.Close()
+			return fmt.Errorf("scan plugin enabled flag: %w", err)
+		}
+		name := strings.TrimSuffix(strings.TrimPrefix(key, "Plugin::"), "::Enabled")
+		state.EnabledFlags[name] = value.String != "0" && value.String != "false"
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return err
+	}
+
+	rows, err = db.QueryContext(ctx, `SELECT DISTINCT plugin_name FROM plugin_schema_migration ORDER BY plugin_name`)
+	if err != nil {
+		return fmt.Errorf("read plugin schema migrations: %w", err)
+	}
+	defer rows.Close()
+	state.SchemaPlugins = nil
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return fmt.Errorf("scan plugin schema migration: %w", err)
+		}
+		state.SchemaPlugins = append(state.SchemaPlugins, name)
+	}
+	return rows.Err()
+}
+
+// RepairDoctorIssues removes the fixable issues and returns how many were
+// repaired. Issues that would lose data or live outside the database are
+// left for the operator.
+func RepairDoctorIssues(ctx context.Context, db *sql.DB, issues []DoctorIssue) (int, error) {
+	repaired := 0
+	for _, issue := range issues {
+		if !issue.Fixable {
+			continue
+		}
+		switch issue.Kind {
+		case DoctorOrphanEnabled:
+			if _, err := db.ExecContext(ctx, database.ConvertPlaceholders(
+				`DELETE FROM sysconfig_modified WHERE name = ?`), pluginConfigKey(issue.Plugin)); err != nil {
+				return repaired, fmt.Errorf("remove enabled flag for %s: %w", issue.Plugin, err)
+			}
+			repaired++
+		}
+	}
+	return repaired, nil
+}
+
+// doctorFileName returns the plugin name implied by a file path.
+func doctorFileName(path string) string {
+	return strings.TrimSuffix(filepath.Base(path), ".wasm")
+}
@@ -0,0 +1,114 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestDiagnose(t *testing.T) {
+	issues := Diagnose(DoctorState{
+		Builtin: []string{"hello", "dashboard-core"},
+		Files: []DoctorFile{
+			{Path: "/plugins/stats.wasm", Manifest: "stats"},
+			{Path: "/plugins/old/hello.wasm", Manifest: "hello"},
+			{Path: "/plugins/faq-v2.wasm", Manifest: "faq"},
+			{Path: "/plugins/broken.wasm", Err: errors.New("invalid magic number")},
+		},
+		EnabledFlags:  map[string]bool{"stats": true, "hello": false, "calendar": true},
+		Policies:      []string{"stats", "legacy"},
+		SchemaPlugins: []string{"faq", "tickets-archive"},
+	})
+
+	want := []struct {
+		kind    DoctorIssueKind
+		plugin  string
+		fixable bool
+	}{
+		{DoctorLoadFailed, "broken", false},
+		{DoctorOrphanEnabled, "calendar", true},
+		{DoctorNameMismatch, "faq", false},
+		{DoctorDuplicate, "hello", false},
+		{DoctorOrphanPolicy, "legacy", false},
+		{DoctorOrphanSchema, "tickets-archive", false},
+	}
+	if len(issues) != len(want) {
+		t.Fatalf("got %d issues, want %d: %+v", len(issues), len(want), issues)
+	}
+	for i, w := range want {
+		got := issues[i]
+		if got.Kind != w.kind || got.Plugin != w.plugin || got.Fixable != w.fixable {
+			t.Errorf("issue %d = %s/%s fixable=%t, want %s/%s fixable=%t",
+				i, got.Kind, got.Plugin, got.Fixable, w.kind, w.plugin, w.fixable)
+		}
+	}
+}
+
+func TestDiagnoseClean(t *testing.T) {
+	issues := Diagnose(DoctorState{
+		Builtin:       []string{"hello"},
+		Files:         []DoctorFile{{Path: "/plugins/stats.wasm", Manifest: "stats"}},
+		EnabledFlags:  map[string]bool{"hello": true, "stats": false},
+		Policies:      []string{"stats"},
+		SchemaPlugins: []string{"stats"},
+	})
+	if len(issues) != 0 {
+		t.Errorf("expected no issues, got %+v", issues)
+	}
+}
+
+func TestDoctorDBStateAndRepair(t *testing.T) {
+	db := newSchemaTestDB(t)
+	ctx := context.Background()
+
+	for _, stmt := range []string{
+		`INSERT INTO sysconfig_modified (sysconfig_default_id, name, is_valid, user_modification_active, effective_value,
+			is_dirty, reset_to_default, create_time, create_by, change_time, change_by)
+			VALUES (9001, 'Plugin::stats::Enabled', 1, 0, '1', 0, 0, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+		`INSERT INTO sysconfig_modified (sysconfig_default_id, name, is_valid, user_modification_active, effective_value,
+			is_dirty, reset_to_default, create_time, create_by, change_time, change_by)
+			VALUES (9002, 'Plugin::calendar::Enabled', 1, 0, '0', 0, 0, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+		`INSERT INTO sysconfig_modified (sysconfig_default_id, name, is_valid, user_modification_active, effective_value,
+			is_dirty, reset_to_default, create_time, create_by, change_time, change_by)
+			VALUES (9003, 'Ticket::Hook', 1, 0, 'Ticket#', 0, 0, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+		`INSERT INTO plugin_schema_migration (plugin_name, version, applied_at) VALUES ('faq', 1, CURRENT_TIMESTAMP)`,
+		`INSERT INTO plugin_schema_migration (plugin_name, version, applied_at) VALUES ('faq', 2, CURRENT_TIMESTAMP)`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("seed failed: %v", err)
+		}
+	}
+
+	state := DoctorState{Builtin: []string{"stats"}}
+	if err := LoadDoctorDBState(ctx, db, &state); err != nil {
+		t.Fatalf("LoadDoctorDBState: %v", err)
+	}
+	if len(state.EnabledFlags) != 2 || !state.EnabledFlags["stats"] || state.EnabledFlags["calendar"] {
+		t.Errorf("unexpected enabled flags: %v", state.EnabledFlags)
+	}
+	if len(state.SchemaPlugins) != 1 || state.SchemaPlugins[0] != "faq" {
+		t.Errorf("unexpected schema plugins: %v", state.SchemaPlugins)
+	}
+
+	repaired, err := RepairDoctorIssues(ctx, db, Diagnose(state))
+	if err != nil {
+		t.Fatalf("RepairDoctorIssues: %v", err)
+	}
+	if repaired != 1 {
+		t.Errorf("repaired %d issues, want 1", repaired)
+	}
+
+	var remaining int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM sysconfig_modified WHERE sysconfig_default_id > 9000`).Scan(&remaining); err != nil {
+		t.Fatal(err)
+	}
+	if remaining != 2 {
+		t.Errorf("expected calendar flag removed and others kept, %d rows remain", remaining)
+	}
+	if err := db.QueryRow(`SELECT COUNT(*) FROM plugin_schema_migration`).Scan(&remaining); err != nil {
+		t.Fatal(err)
+	}
+	if remaining != 2 {
+		t.Errorf("schema records must be left alone, %d rows remain", remaining)
+	}
+}
@@ -5,6 +5,8 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"html"
+	"strings"
 	"time"
 
 	"github.com/goatkit/goatflow/internal/plugin"
@@ -15,6 +17,7 @@ import (
 type HelloPlugin struct {
 	host      plugin.HostAPI
 	callCount int
+	greeting  string // set on the admin settings page
 }
 
 // NewHelloPlugin creates a new hello plugin instance.
@@ -52,7 +55,7 @@ func (p *HelloPlugin) GKRegister() plugin.GKRegistration {
 				ID:       "hello-menu",
 				Label:    "Hello Plugin",
 				Icon:     "hand-wave",
-				Path:     "/admin/plugins/hello",
+				Path:     "/admin/plugins/hello/pages/settings",
 				Location: "admin",
 				Order:    100,
 			},
@@ -71,6 +74,16 @@ func (p *HelloPlugin) GKRegister() plugin.GKRegistration {
 			},
 		},
 
+		AdminPages: []plugin.AdminPageSpec{
+			{
+				ID:          "settings",
+				Title:       "Hello Settings",
+				Description: "Choose the greeting the plugin uses",
+				Icon:        "fa-hand",
+				Handler:     "settings_page",
+			},
+		},
+
 		Jobs: []plugin.JobSpec{
 			{
 				ID:          "hello-job",
@@ -114,6 +127,8 @@ func (p *HelloPlugin) Call(ctx context.Context, fn string, args json.RawMessage)
 		return p.handleWidget(ctx)
 	case "scheduled_hello":
 		return p.handleScheduledHello(ctx)
+	case "settings_page":
+		return p.handleSettingsPage(args)
 	default:
 		return nil, fmt.Errorf("unknown function: %s", fn)
 	}
@@ -157,7 +172,7 @@ func (p *HelloPlugin) handleHello(ctx context.Context, args json.RawMessage) (js
 	}
 
 	return json.Marshal(map[string]any{
-		"message":   fmt.Sprintf("Hello, %s!", name),
+		"message":   fmt.Sprintf("%s, %s!", p.currentGreeting(), name),
 		"timestamp": time.Now().UTC().Format(time.RFC3339),
 	})
 }
@@ -189,3 +204,43 @@ func (p *HelloPlugin) handleScheduledHello(ctx context.Context) (json.RawMessage
 	}
 	return json.Marshal(map[string]bool{"ok": true})
 }
+
+func (p *HelloPlugin) currentGreeting() string {
+	if p.greeting == "" {
+		return "Hello"
+	}
+	return p.greeting
+}
+
+// handleSettingsPage renders the admin settings page and saves its form.
+// The host checks admin access and the request origin before calling it.
+func (p *HelloPlugin) handleSettingsPage(args json.RawMessage) (json.RawMessage, error) {
+	var req struct {
+		Method string         `json:"_method"`
+		URL    string         `json:"_url"`
+		Form   map[string]any `json:"form"`
+	}
+	if err := json.Unmarshal(args, &req); err != nil {
+		return nil, err
+	}
+
+	var problem string
+	if req.Method == "POST" {
+		greeting, _ := req.Form["greeting"].(string)
+		if greeting = strings.TrimSpace(greeting); greeting != "" {
+			p.greeting = greeting
+			// No HTML: the host redirects back to the page
+			return json.Marshal(map[string]string{})
+		}
+		problem = `<p class="text-sm" style="color: var(--gk-error);">Greeting is required.</p>`
+	}
+
+	page := fmt.Sprintf(`<form method="post" action="%s" class="space-y-4">
+		%s
+		<label class="form-label" for="hello-greeting">Greeting</label>
+		<input id="hello-greeting" name="greeting" class="gk-input-neon w-full" value="%s">
+		<button type="submit" class="gk-btn-neon">Save</button>
+	</form>`, html.EscapeString(req.URL), problem, html.EscapeString(p.currentGreeting()))
+
+	return json.Marshal(map[string]string{"html": page})
+}
@@ -3,6 +3,7 @@ package example
 import (
 	"context"
 	"encoding/json"
+	"strings"
 	"testing"
 )
 
@@ -17,13 +18,23 @@ func (m *mockHostAPI) DBQuery(ctx context.Context, query string, args ...any) ([
 func (m *mockHostAPI) DBExec(ctx context.Context, query string, args ...any) (int64, error) {
 	return 0, nil
 }
+func (m *mockHostAPI) DBBegin(ctx context.Context, dbName string) (string, error) {
+	return "tx-1", nil
+}
+func (m *mockHostAPI) DBCommit(ctx context.Context, txID string) error   { return nil }
+func (m *mockHostAPI) DBRollback(ctx context.Context, txID string) error { return nil }
 func (m *mockHostAPI) CacheGet(ctx context.Context, key string) ([]byte, bool, error) {
 	return nil, false, nil
 }
 func (m *mockHostAPI) CacheSet(ctx context.Context, key string, value []byte, ttlSeconds int) error {
 	return nil
 }
-func (m *mockHostAPI) CacheDelete(ctx context.Context, key string) error { return nil }
+func (m *mockHostAPI) CacheDelete(ctx context.Context, key string) error              { return nil }
+func (m *mockHostAPI) FilePut(ctx context.Context, path string, content []byte) error { return nil }
+func (m *mockHostAPI) FileGet(ctx context.Context, path string) ([]byte, bool, error) {
+	return nil, false, nil
+}
+func (m *mockHostAPI) FileDelete(ctx context.Context, path string) error { return nil }
 func (m *mockHostAPI) HTTPRequest(ctx context.Context, method, url string, headers map[string]string, body []byte) (int, []byte, error) {
 	return 200, nil, nil
 }
@@ -34,6 +45,9 @@ func (m *mockHostAPI) Log(ctx context.Context, level, message string, fields map
 	m.logs = append(m.logs, message)
 }
 func (m *mockHostAPI) ConfigGet(ctx context.Context, key string) (string, error) { return "", nil }
+func (m *mockHostAPI) FeatureEnabled(ctx context.Context, flag string, userID int) (bool, error) {
+	return false, nil
+}
 func (m *mockHostAPI) Translate(ctx context.Context, key string, args ...any) string {
 	return ""
 }
@@ -213,3 +227,42 @@ func TestHelloPluginMenuItems(t *testing.T) {
 		t.Error("expected hello-menu menu item")
 	}
 }
+
+func TestHelloPluginSettingsPage(t *testing.T) {
+	ctx := context.Background()
+	p := NewHelloPlugin()
+
+	call := func(args map[string]any) map[string]string {
+		t.Helper()
+		raw, _ := json.Marshal(args)
+		result, err := p.Call(ctx, "settings_page", raw)
+		if err != nil {
+			t.Fatalf("settings_page failed: %v", err)
+		}
+		var resp map[string]string
+		if err := json.Unmarshal(result, &resp); err != nil {
+			t.Fatalf("invalid response: %v", err)
+		}
+		return resp
+	}
+
+	page := call(map[string]any{"_method": "GET", "_url": "/admin/plugins/hello/pages/settings"})
+	if !strings.Contains(page["html"], `action="/admin/plugins/hello/pages/settings"`) || !strings.Contains(page["html"], `value="Hello"`) {
+		t.Errorf("expected settings form, got %s", page["html"])
+	}
+
+	invalid := call(map[string]any{"_method": "POST", "form": map[string]any{"greeting": " "}})
+	if !strings.Contains(invalid["html"], "Greeting is required") {
+		t.Errorf("expected validation error, got %s", invalid["html"])
+	}
+
+	saved := call(map[string]any{"_method": "POST", "form": map[string]any{"greeting": "Howdy"}})
+	if saved["html"] != "" {
+		t.Errorf("expected no HTML after save, got %s", saved["html"])
+	}
+
+	result, _ := p.Call(ctx, "hello", json.RawMessage(`{"name":"Ada"}`))
+	if !strings.Contains(string(result), "Howdy, Ada!") {
+		t.Errorf("expected saved greeting, got %s", result)
+	}
+}
@@ -5,16 +5,24 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 
 	"github.com/goatkit/goatflow/internal/plugin"
 	grpcplugin "github.com/goatkit/goatflow/internal/plugin/grpc"
+	"github.com/goatkit/goatflow/pkg/plugin/hostclient"
 )
 
 // HelloGRPCPlugin is a simple example gRPC plugin.
 type HelloGRPCPlugin struct {
 	config map[string]string
+	host   *hostclient.Client
+}
+
+// SetHostClient receives the host API client before Init.
+func (p *HelloGRPCPlugin) SetHostClient(host *hostclient.Client) {
+	p.host = host
 }
 
 // GKRegister returns the plugin registration.
@@ -75,6 +83,22 @@ func (p *HelloGRPCPlugin) Call(fn string, args json.RawMessage) (json.RawMessage
 			"type":    "grpc",
 		})
 
+	case "get_ticket":
+		if p.host == nil {
+			return nil, fmt.Errorf("host API not connected")
+		}
+		var req struct {
+			ID int64 `json:"id"`
+		}
+		if err := json.Unmarshal(args, &req); err != nil {
+			return nil, err
+		}
+		ticket, err := p.host.Tickets().Get(context.Background(), req.ID)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(ticket)
+
 	default:
 		return nil, fmt.Errorf("unknown function: %s", fn)
 	}
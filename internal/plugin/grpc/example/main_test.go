@@ -133,6 +133,16 @@ func TestHelloGRPCPlugin_Call_UnknownFunction(t *testing.T) {
 	}
 }
 
+func TestHelloGRPCPlugin_Call_GetTicketWithoutHost(t *testing.T) {
+	p := &HelloGRPCPlugin{}
+	p.Init(nil)
+
+	_, err := p.Call("get_ticket", json.RawMessage(`{"id":1}`))
+	if err == nil {
+		t.Error("expected error without a host client")
+	}
+}
+
 func TestHelloGRPCPlugin_Shutdown(t *testing.T) {
 	p := &HelloGRPCPlugin{}
 	p.Init(nil)
@@ -3,46 +3,126 @@ package grpc
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/rpc"
+	"sync"
+	"time"
 
 	"github.com/goatkit/goatflow/internal/plugin"
+	"github.com/goatkit/goatflow/internal/tracing"
 )
 
 // HostAPIRPCServer exposes HostAPI to plugins via RPC.
 // This runs on the host side and handles plugin callbacks.
 type HostAPIRPCServer struct {
 	Host plugin.HostAPI
+
+	mu     sync.RWMutex
+	plugin string // Name of the plugin served, recorded by the host
+}
+
+// NewHostAPIRPCServer creates a host API server for the named plugin.
+func NewHostAPIRPCServer(host plugin.HostAPI, pluginName string) *HostAPIRPCServer {
+	return &HostAPIRPCServer{Host: host, plugin: pluginName}
+}
+
+// setPlugin records the name of the plugin the server serves. Calls are
+// made on its behalf; the CallerPlugin a request carries is ignored.
+func (s *HostAPIRPCServer) setPlugin(name string) {
+	s.mu.Lock()
+	s.plugin = name
+	s.mu.Unlock()
+}
+
+func (s *HostAPIRPCServer) pluginName() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.plugin
 }
 
 // HostAPIRequest is a generic host API request.
 type HostAPIRequest struct {
 	Method       string          // Method name (e.g., "db_query", "cache_get")
 	Args         json.RawMessage // JSON-encoded arguments
-	CallerPlugin string          // Name of the calling plugin (for error context)
+	CallerPlugin string          // Name the plugin reports; the host uses its own record instead
+	Deadline     time.Time       // Host stops working on the call after this; zero means no deadline
+	TraceParent  string          // W3C traceparent of the plugin call; empty when untraced
 }
 
 // HostAPIResponse is a generic host API response.
 type HostAPIResponse struct {
 	Result json.RawMessage
 	Error  string
+	Code   string // One of the ErrCode constants, so plugins can tell errors apart
+}
+
+// Error codes reported in HostAPIResponse.Code.
+const (
+	ErrCodePluginNotFound = "plugin_not_found"
+	ErrCodePluginDisabled = "plugin_disabled"
+	ErrCodeUnknownMethod  = "unknown_method"
+	ErrCodeDeadline       = "deadline_exceeded"
+	ErrCodeQuotaExceeded  = "quota_exceeded"
+)
+
+// hostErrorCode classifies a host API error for HostAPIResponse.Code.
+func hostErrorCode(err error) string {
+	var notFound *plugin.PluginNotFoundError
+	var disabled *plugin.PluginDisabledError
+	var unknown *UnknownMethodError
+	switch {
+	case errors.As(err, &notFound):
+		return ErrCodePluginNotFound
+	case errors.As(err, &disabled):
+		return ErrCodePluginDisabled
+	case errors.As(err, &unknown):
+		return ErrCodeUnknownMethod
+	case errors.Is(err, context.DeadlineExceeded):
+		return ErrCodeDeadline
+	case errors.Is(err, plugin.ErrFileTooLarge), errors.Is(err, plugin.ErrFileQuotaExceeded):
+		return ErrCodeQuotaExceeded
+	}
+	return ""
 }
 
 // Call handles all host API calls from plugins.
 func (s *HostAPIRPCServer) Call(req HostAPIRequest, resp *HostAPIResponse) error {
-	ctx := context.Background()
-	// Set caller plugin in context for better error messages
-	if req.CallerPlugin != "" {
-		ctx = context.WithValue(ctx, plugin.PluginCallerKey, req.CallerPlugin)
+	// The caller is the plugin the host started this server for, never the
+	// name in the request, so a plugin cannot act as another one.
+	caller := s.pluginName()
+	if caller == "" {
+		resp.Error = "host API call from a plugin that has not registered"
+		return nil
+	}
+	ctx := tracing.ContextWithTraceParent(context.Background(), req.TraceParent)
+	ctx = context.WithValue(ctx, plugin.PluginCallerKey, caller)
+	if !req.Deadline.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, req.Deadline)
+		defer cancel()
 	}
 	result, err := dispatchHostCall(ctx, s.Host, req.Method, req.Args)
 	if err != nil {
 		resp.Error = err.Error()
+		resp.Code = hostErrorCode(err)
 		return nil
 	}
 	resp.Result = result
 	return nil
 }
 
+// DispatchHostCall runs a host API call by method name with JSON arguments,
+// as plugins running outside the host process make them. Errors can be
+// classified with HostErrorCode.
+func DispatchHostCall(ctx context.Context, host plugin.HostAPI, method string, args json.RawMessage) (json.RawMessage, error) {
+	return dispatchHostCall(ctx, host, method, args)
+}
+
+// HostErrorCode returns the ErrCode constant for a host API error, or "".
+func HostErrorCode(err error) string {
+	return hostErrorCode(err)
+}
+
 // dispatchHostCall routes the call to the appropriate HostAPI method.
 func dispatchHostCall(ctx context.Context, host plugin.HostAPI, method string, args json.RawMessage) (json.RawMessage, error) {
 	switch method {
@@ -50,11 +130,12 @@ func dispatchHostCall(ctx context.Context, host plugin.HostAPI, method string, a
 		var req struct {
 			Query string `json:"query"`
 			Args  []any  `json:"args"`
+			Tx    string `json:"tx"`
 		}
 		if err := json.Unmarshal(args, &req); err != nil {
 			return nil, err
 		}
-		rows, err := host.DBQuery(ctx, req.Query, req.Args...)
+		rows, err := host.DBQuery(plugin.WithTxID(ctx, req.Tx), req.Query, req.Args...)
 		if err != nil {
 			return nil, err
 		}
@@ -64,16 +145,50 @@ func dispatchHostCall(ctx context.Context, host plugin.HostAPI, method string, a
 		var req struct {
 			Query string `json:"query"`
 			Args  []any  `json:"args"`
+			Tx    string `json:"tx"`
 		}
 		if err := json.Unmarshal(args, &req); err != nil {
 			return nil, err
 		}
-		affected, err := host.DBExec(ctx, req.Query, req.Args...)
+		affected, err := host.DBExec(plugin.WithTxID(ctx, req.Tx), req.Query, req.Args...)
 		if err != nil {
 			return nil, err
 		}
 		return json.Marshal(map[string]int64{"affected": affected})
 
+	case "db_begin":
+		var req struct {
+			Database string `json:"database"`
+		}
+		if len(args) > 0 {
+			if err := json.Unmarshal(args, &req); err != nil {
+				return nil, err
+			}
+		}
+		txID, err := host.DBBegin(ctx, req.Database)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(map[string]string{"tx": txID})
+
+	case "db_commit", "db_rollback":
+		var req struct {
+			Tx string `json:"tx"`
+		}
+		if err := json.Unmarshal(args, &req); err != nil {
+			return nil, err
+		}
+		var err error
+		if method == "db_commit" {
+			err = host.DBCommit(ctx, req.Tx)
+		} else {
+			err = host.DBRollback(ctx, req.Tx)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(map[string]bool{"ok": true})
+
 	case "cache_get":
 		var req struct {
 			Key string `json:"key"`
@@ -102,6 +217,56 @@ func dispatchHostCall(ctx context.Context, host plugin.HostAPI, method string, a
 		}
 		return json.Marshal(map[string]bool{"ok": true})
 
+	case "cache_delete":
+		var req struct {
+			Key string `json:"key"`
+		}
+		if err := json.Unmarshal(args, &req); err != nil {
+			return nil, err
+		}
+		if err := host.CacheDelete(ctx, req.Key); err != nil {
+			return nil, err
+		}
+		return json.Marshal(map[string]bool{"ok": true})
+
+	case "file_put":
+		var req struct {
+			Path    string `json:"path"`
+			Content []byte `json:"content"`
+		}
+		if err := json.Unmarshal(args, &req); err != nil {
+			return nil, err
+		}
+		if err := host.FilePut(ctx, req.Path, req.Content); err != nil {
+			return nil, err
+		}
+		return json.Marshal(map[string]bool{"ok": true})
+
+	case "file_get":
+		var req struct {
+			Path string `json:"path"`
+		}
+		if err := json.Unmarshal(args, &req); err != nil {
+			return nil, err
+		}
+		content, found, err := host.FileGet(ctx, req.Path)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(map[string]any{"content": content, "found": found})
+
+	case "file_delete":
+		var req struct {
+			Path string `json:"path"`
+		}
+		if err := json.Unmarshal(args, &req); err != nil {
+			return nil, err
+		}
+		if err := host.FileDelete(ctx, req.Path); err != nil {
+			return nil, err
+		}
+		return json.Marshal(map[string]bool{"ok": true})
+
 	case "http_request":
 		var req struct {
 			Method  string            `json:"method"`
@@ -147,6 +312,20 @@ func dispatchHostCall(ctx context.Context, host plugin.HostAPI, method string, a
 		}
 		return json.Marshal(map[string]string{"value": val})
 
+	case "feature_enabled":
+		var req struct {
+			Flag   string `json:"flag"`
+			UserID int    `json:"user_id"`
+		}
+		if err := json.Unmarshal(args, &req); err != nil {
+			return nil, err
+		}
+		on, err := host.FeatureEnabled(ctx, req.Flag, req.UserID)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(map[string]bool{"enabled": on})
+
 	case "translate":
 		var req struct {
 			Key  string `json:"key"`
@@ -187,6 +366,7 @@ func (e *UnknownMethodError) Error() string {
 // This runs on the plugin side.
 type HostAPIRPCClient struct {
 	client *rpc.Client
+	plugin string // Sent as CallerPlugin
 }
 
 // NewHostAPIRPCClient creates a new host API client.
@@ -196,19 +376,37 @@ func NewHostAPIRPCClient(client *rpc.Client) *HostAPIRPCClient {
 
 // Call makes a host API call.
 func (c *HostAPIRPCClient) Call(method string, args any) (json.RawMessage, error) {
+	return c.CallContext(context.Background(), method, args)
+}
+
+// CallContext makes a host API call that gives up when ctx is done. The
+// context deadline is passed on so the host stops working on the call too.
+func (c *HostAPIRPCClient) CallContext(ctx context.Context, method string, args any) (json.RawMessage, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	argsJSON, err := json.Marshal(args)
 	if err != nil {
 		return nil, err
 	}
 
-	req := HostAPIRequest{Method: method, Args: argsJSON}
+	req := HostAPIRequest{Method: method, Args: argsJSON, CallerPlugin: c.plugin, TraceParent: tracing.TraceParent(ctx)}
+	if deadline, ok := ctx.Deadline(); ok {
+		req.Deadline = deadline
+	}
 	var resp HostAPIResponse
 
-	if err := c.client.Call("HostAPI.Call", req, &resp); err != nil {
-		return nil, err
+	call := c.client.Go("HostAPI.Call", req, &resp, make(chan *rpc.Call, 1))
+	select {
+	case <-call.Done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if call.Error != nil {
+		return nil, call.Error
 	}
 	if resp.Error != "" {
-		return nil, &HostError{Message: resp.Error}
+		return nil, &HostError{Message: resp.Error, Code: resp.Code}
 	}
 	return resp.Result, nil
 }
@@ -216,12 +414,18 @@ func (c *HostAPIRPCClient) Call(method string, args any) (json.RawMessage, error
 // HostError represents an error from the host.
 type HostError struct {
 	Message string
+	Code    string // One of the ErrCode constants, or empty
 }
 
 func (e *HostError) Error() string {
 	return e.Message
 }
 
+// ErrorCode returns the error code reported by the host.
+func (e *HostError) ErrorCode() string {
+	return e.Code
+}
+
 // Convenience methods for common operations
 
 func (c *HostAPIRPCClient) DBQuery(query string, args ...any) ([]map[string]any, error) {
@@ -246,6 +450,85 @@ func (c *HostAPIRPCClient) DBExec(query string, args ...any) (int64, error) {
 	return resp.Affected, nil
 }
 
+func (c *HostAPIRPCClient) DBBegin(database string) (string, error) {
+	result, err := c.Call("db_begin", map[string]any{"database": database})
+	if err != nil {
+		return "", err
+	}
+	var resp struct {
+		Tx string `json:"tx"`
+	}
+	json.Unmarshal(result, &resp)
+	return resp.Tx, nil
+}
+
+func (c *HostAPIRPCClient) DBCommit(txID string) error {
+	_, err := c.Call("db_commit", map[string]any{"tx": txID})
+	return err
+}
+
+func (c *HostAPIRPCClient) DBRollback(txID string) error {
+	_, err := c.Call("db_rollback", map[string]any{"tx": txID})
+	return err
+}
+
+// DBExecTx runs a statement inside a transaction opened by DBBegin.
+func (c *HostAPIRPCClient) DBExecTx(txID, query string, args ...any) (int64, error) {
+	result, err := c.Call("db_exec", map[string]any{"query": query, "args": args, "tx": txID})
+	if err != nil {
+		return 0, err
+	}
+	var resp struct {
+		Affected int64 `json:"affected"`
+	}
+	json.Unmarshal(result, &resp)
+	return resp.Affected, nil
+}
+
+// FeatureEnabled reports whether a host feature flag is on for a user.
+func (c *HostAPIRPCClient) FeatureEnabled(flag string, userID int) (bool, error) {
+	result, err := c.Call("feature_enabled", map[string]any{"flag": flag, "user_id": userID})
+	if err != nil {
+		return false, err
+	}
+	var resp struct {
+		Enabled bool `json:"enabled"`
+	}
+	if err := json.Unmarshal(result, &resp); err != nil {
+		return false, err
+	}
+	return resp.Enabled, nil
+}
+
+// FilePut stores a file in the plugin's file storage.
+func (c *HostAPIRPCClient) FilePut(path string, content []byte) error {
+	_, err := c.Call("file_put", map[string]any{"path": path, "content": content})
+	return err
+}
+
+// FileGet reads a file from the plugin's file storage; found is false when
+// it does not exist.
+func (c *HostAPIRPCClient) FileGet(path string) ([]byte, bool, error) {
+	result, err := c.Call("file_get", map[string]any{"path": path})
+	if err != nil {
+		return nil, false, err
+	}
+	var resp struct {
+		Content []byte `json:"content"`
+		Found   bool   `json:"found"`
+	}
+	if err := json.Unmarshal(result, &resp); err != nil {
+		return nil, false, err
+	}
+	return resp.Content, resp.Found, nil
+}
+
+// FileDelete removes a file from the plugin's file storage.
+func (c *HostAPIRPCClient) FileDelete(path string) error {
+	_, err := c.Call("file_delete", map[string]any{"path": path})
+	return err
+}
+
 func (c *HostAPIRPCClient) CallPlugin(pluginName, fn string, args json.RawMessage) (json.RawMessage, error) {
 	return c.Call("plugin_call", map[string]any{
 		"plugin":   pluginName,
@@ -3,9 +3,13 @@ package grpc
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net"
 	"net/rpc"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/goatkit/goatflow/internal/plugin"
 )
@@ -15,6 +19,7 @@ type mockHostAPI struct {
 	queryResults []map[string]any
 	execAffected int64
 	cacheData    map[string][]byte
+	fileData     map[string][]byte
 	logs         []string
 	configData   map[string]string
 }
@@ -24,6 +29,7 @@ func newMockHostAPI() *mockHostAPI {
 		queryResults: []map[string]any{{"id": 1, "name": "test"}},
 		execAffected: 1,
 		cacheData:    make(map[string][]byte),
+		fileData:     make(map[string][]byte),
 		configData:   map[string]string{"app.name": "GoatFlow"},
 	}
 }
@@ -36,6 +42,14 @@ func (m *mockHostAPI) DBExec(ctx context.Context, query string, args ...any) (in
 	return m.execAffected, nil
 }
 
+func (m *mockHostAPI) DBBegin(ctx context.Context, dbName string) (string, error) {
+	return "tx-1", nil
+}
+
+func (m *mockHostAPI) DBCommit(ctx context.Context, txID string) error { return nil }
+
+func (m *mockHostAPI) DBRollback(ctx context.Context, txID string) error { return nil }
+
 func (m *mockHostAPI) CacheGet(ctx context.Context, key string) ([]byte, bool, error) {
 	v, ok := m.cacheData[key]
 	return v, ok, nil
@@ -51,6 +65,25 @@ func (m *mockHostAPI) CacheDelete(ctx context.Context, key string) error {
 	return nil
 }
 
+// FilePut allows files of up to 8 bytes, the mock's quota.
+func (m *mockHostAPI) FilePut(ctx context.Context, path string, content []byte) error {
+	if len(content) > 8 {
+		return fmt.Errorf("%w (0 of 8 bytes used)", plugin.ErrFileQuotaExceeded)
+	}
+	m.fileData[path] = content
+	return nil
+}
+
+func (m *mockHostAPI) FileGet(ctx context.Context, path string) ([]byte, bool, error) {
+	v, ok := m.fileData[path]
+	return v, ok, nil
+}
+
+func (m *mockHostAPI) FileDelete(ctx context.Context, path string) error {
+	delete(m.fileData, path)
+	return nil
+}
+
 func (m *mockHostAPI) HTTPRequest(ctx context.Context, method, url string, headers map[string]string, body []byte) (int, []byte, error) {
 	return 200, []byte(`{"ok":true}`), nil
 }
@@ -67,6 +100,10 @@ func (m *mockHostAPI) ConfigGet(ctx context.Context, key string) (string, error)
 	return m.configData[key], nil
 }
 
+func (m *mockHostAPI) FeatureEnabled(ctx context.Context, flag string, userID int) (bool, error) {
+	return flag == "beta" && userID == 7, nil
+}
+
 func (m *mockHostAPI) Translate(ctx context.Context, key string, args ...any) string {
 	return key
 }
@@ -110,6 +147,25 @@ func TestDispatchHostCall(t *testing.T) {
 		}
 	})
 
+	t.Run("db_begin_commit_rollback", func(t *testing.T) {
+		result, err := dispatchHostCall(ctx, host, "db_begin", nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		var begin map[string]string
+		json.Unmarshal(result, &begin)
+		if begin["tx"] != "tx-1" {
+			t.Errorf("expected tx id tx-1, got %q", begin["tx"])
+		}
+
+		txArgs, _ := json.Marshal(map[string]string{"tx": begin["tx"]})
+		for _, method := range []string{"db_commit", "db_rollback"} {
+			if _, err := dispatchHostCall(ctx, host, method, txArgs); err != nil {
+				t.Errorf("%s: unexpected error: %v", method, err)
+			}
+		}
+	})
+
 	t.Run("cache_get_miss", func(t *testing.T) {
 		args, _ := json.Marshal(map[string]string{"key": "nonexistent"})
 		result, err := dispatchHostCall(ctx, host, "cache_get", args)
@@ -209,6 +265,57 @@ func TestDispatchHostCall(t *testing.T) {
 		}
 	})
 
+	t.Run("feature_enabled", func(t *testing.T) {
+		for userID, want := range map[int]bool{7: true, 8: false} {
+			args, _ := json.Marshal(map[string]any{"flag": "beta", "user_id": userID})
+			result, err := dispatchHostCall(ctx, host, "feature_enabled", args)
+			if err != nil {
+				t.Fatalf("feature_enabled error: %v", err)
+			}
+			var resp map[string]bool
+			json.Unmarshal(result, &resp)
+			if resp["enabled"] != want {
+				t.Errorf("user %d: expected enabled=%v, got %v", userID, want, resp["enabled"])
+			}
+		}
+	})
+
+	t.Run("files", func(t *testing.T) {
+		args, _ := json.Marshal(map[string]any{"path": "notes/a.txt", "content": []byte("hello")})
+		if _, err := dispatchHostCall(ctx, host, "file_put", args); err != nil {
+			t.Fatalf("file_put error: %v", err)
+		}
+		get := func() (content []byte, found bool) {
+			args, _ := json.Marshal(map[string]string{"path": "notes/a.txt"})
+			result, err := dispatchHostCall(ctx, host, "file_get", args)
+			if err != nil {
+				t.Fatalf("file_get error: %v", err)
+			}
+			var resp struct {
+				Content []byte `json:"content"`
+				Found   bool   `json:"found"`
+			}
+			json.Unmarshal(result, &resp)
+			return resp.Content, resp.Found
+		}
+		if content, found := get(); !found || string(content) != "hello" {
+			t.Errorf("expected the stored file, got %q (found=%v)", content, found)
+		}
+		args, _ = json.Marshal(map[string]string{"path": "notes/a.txt"})
+		if _, err := dispatchHostCall(ctx, host, "file_delete", args); err != nil {
+			t.Fatalf("file_delete error: %v", err)
+		}
+		if _, found := get(); found {
+			t.Error("expected the file to be deleted")
+		}
+
+		args, _ = json.Marshal(map[string]any{"path": "big.bin", "content": []byte("more than eight bytes")})
+		_, err := dispatchHostCall(ctx, host, "file_put", args)
+		if code := hostErrorCode(err); code != ErrCodeQuotaExceeded {
+			t.Errorf("expected code %q over quota, got %q (%v)", ErrCodeQuotaExceeded, code, err)
+		}
+	})
+
 	t.Run("plugin_call", func(t *testing.T) {
 		args, _ := json.Marshal(map[string]any{
 			"plugin":   "other-plugin",
@@ -236,7 +343,7 @@ func TestDispatchHostCall(t *testing.T) {
 
 func TestHostAPIRPCServer_Call(t *testing.T) {
 	host := newMockHostAPI()
-	server := &HostAPIRPCServer{Host: host}
+	server := &HostAPIRPCServer{Host: host, plugin: "test-plugin"}
 
 	t.Run("successful call", func(t *testing.T) {
 		req := HostAPIRequest{
@@ -287,12 +394,98 @@ func TestHostAPIRPCServer_Call(t *testing.T) {
 			t.Errorf("Call returned error: %v", err)
 		}
 	})
+
+	t.Run("server without a registered plugin", func(t *testing.T) {
+		unnamed := &HostAPIRPCServer{Host: host}
+		req := HostAPIRequest{
+			Method:       "log",
+			Args:         json.RawMessage(`{"level":"info","message":"test","fields":{}}`),
+			CallerPlugin: "test-plugin",
+		}
+		var resp HostAPIResponse
+
+		if err := unnamed.Call(req, &resp); err != nil {
+			t.Errorf("Call returned error: %v", err)
+		}
+		if resp.Error == "" {
+			t.Error("expected calls to be refused until the plugin has registered")
+		}
+	})
+}
+
+// mapFileStore is a plugin.FileStore in a map.
+type mapFileStore map[string][]byte
+
+func (s mapFileStore) Put(_ context.Context, path string, content []byte) error {
+	s[path] = content
+	return nil
+}
+
+func (s mapFileStore) Get(_ context.Context, path string) ([]byte, bool, error) {
+	v, ok := s[path]
+	return v, ok, nil
+}
+
+func (s mapFileStore) Delete(_ context.Context, path string) error {
+	delete(s, path)
+	return nil
+}
+
+func (s mapFileStore) Size(_ context.Context, path string) (int64, error) {
+	var n int64
+	for k, v := range s {
+		if k == path || strings.HasPrefix(k, path+"/") {
+			n += int64(len(v))
+		}
+	}
+	return n, nil
+}
+
+func TestHostAPIRPCServer_SpoofedCallerPlugin(t *testing.T) {
+	store := mapFileStore{"plugins/victim/secret.txt": []byte("s3cret")}
+	server := &HostAPIRPCServer{Host: plugin.NewProdHostAPI(plugin.WithFileStore(store))}
+	server.setPlugin("attacker")
+
+	var resp HostAPIResponse
+	err := server.Call(HostAPIRequest{
+		Method:       "file_get",
+		Args:         json.RawMessage(`{"path":"secret.txt"}`),
+		CallerPlugin: "victim",
+	}, &resp)
+	if err != nil {
+		t.Fatalf("Call returned error: %v", err)
+	}
+	var got struct {
+		Content []byte `json:"content"`
+		Found   bool   `json:"found"`
+	}
+	if err := json.Unmarshal(resp.Result, &got); err != nil {
+		t.Fatalf("unmarshal %s: %v", resp.Result, err)
+	}
+	if got.Found {
+		t.Fatalf("spoofed CallerPlugin read another plugin's file: %q", got.Content)
+	}
+
+	resp = HostAPIResponse{}
+	if err := server.Call(HostAPIRequest{
+		Method:       "file_put",
+		Args:         json.RawMessage(`{"path":"secret.txt","content":"b3duZWQ="}`),
+		CallerPlugin: "victim",
+	}, &resp); err != nil || resp.Error != "" {
+		t.Fatalf("file_put: %v %s", err, resp.Error)
+	}
+	if string(store["plugins/victim/secret.txt"]) != "s3cret" {
+		t.Error("spoofed CallerPlugin overwrote another plugin's file")
+	}
+	if _, ok := store["plugins/attacker/secret.txt"]; !ok {
+		t.Errorf("expected the file under the calling plugin's directory, store has %v", store)
+	}
 }
 
 func TestHostAPIRPCClient(t *testing.T) {
 	// Set up a real RPC server for the client to talk to
 	host := newMockHostAPI()
-	server := &HostAPIRPCServer{Host: host}
+	server := &HostAPIRPCServer{Host: host, plugin: "test-plugin"}
 
 	rpcServer := rpc.NewServer()
 	err := rpcServer.RegisterName("HostAPI", server)
@@ -560,3 +753,90 @@ func TestPluginMap(t *testing.T) {
 		t.Error("expected gkplugin in plugin map")
 	}
 }
+
+func TestHostErrorCode(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{fmt.Errorf("call: %w", &plugin.PluginNotFoundError{PluginName: "stats"}), ErrCodePluginNotFound},
+		{&plugin.PluginDisabledError{PluginName: "stats"}, ErrCodePluginDisabled},
+		{&UnknownMethodError{Method: "nope"}, ErrCodeUnknownMethod},
+		{fmt.Errorf("query failed: %w", context.DeadlineExceeded), ErrCodeDeadline},
+		{errors.New("query failed"), ""},
+	}
+	for _, tt := range tests {
+		if got := hostErrorCode(tt.err); got != tt.want {
+			t.Errorf("hostErrorCode(%v) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
+
+// deadlineHost reports the deadline it sees on plugin calls.
+type deadlineHost struct {
+	mockHostAPI
+	deadline time.Time
+}
+
+func (h *deadlineHost) CallPlugin(ctx context.Context, pluginName, function string, args json.RawMessage) (json.RawMessage, error) {
+	h.deadline, _ = ctx.Deadline()
+	return nil, &plugin.PluginNotFoundError{PluginName: pluginName}
+}
+
+func TestHostAPIRPCServer_DeadlineAndCode(t *testing.T) {
+	host := &deadlineHost{mockHostAPI: *newMockHostAPI()}
+	server := &HostAPIRPCServer{Host: host, plugin: "test-plugin"}
+	deadline := time.Now().Add(time.Minute)
+
+	var resp HostAPIResponse
+	err := server.Call(HostAPIRequest{
+		Method:   "plugin_call",
+		Args:     json.RawMessage(`{"plugin":"stats","function":"count"}`),
+		Deadline: deadline,
+	}, &resp)
+	if err != nil {
+		t.Fatalf("Call returned error: %v", err)
+	}
+	if !host.deadline.Equal(deadline) {
+		t.Errorf("host saw deadline %v, want %v", host.deadline, deadline)
+	}
+	if resp.Code != ErrCodePluginNotFound {
+		t.Errorf("expected code %q, got %q", ErrCodePluginNotFound, resp.Code)
+	}
+}
+
+func TestDispatchHostCall_CacheDelete(t *testing.T) {
+	host := newMockHostAPI()
+	host.cacheData["k"] = []byte("v")
+
+	if _, err := dispatchHostCall(context.Background(), host, "cache_delete", json.RawMessage(`{"key":"k"}`)); err != nil {
+		t.Fatalf("cache_delete error: %v", err)
+	}
+	if _, ok := host.cacheData["k"]; ok {
+		t.Error("expected key to be deleted")
+	}
+}
+
+func TestHostAPIRPCClient_CallContext(t *testing.T) {
+	rpcServer := rpc.NewServer()
+	if err := rpcServer.RegisterName("HostAPI", &HostAPIRPCServer{Host: &deadlineHost{mockHostAPI: *newMockHostAPI()}, plugin: "test-plugin"}); err != nil {
+		t.Fatalf("Failed to register RPC server: %v", err)
+	}
+	clientConn, serverConn := net.Pipe()
+	defer clientConn.Close()
+	defer serverConn.Close()
+	go rpcServer.ServeConn(serverConn)
+	client := NewHostAPIRPCClient(rpc.NewClient(clientConn))
+
+	_, err := client.CallContext(context.Background(), "plugin_call", map[string]any{"plugin": "stats"})
+	var hostErr *HostError
+	if !errors.As(err, &hostErr) || hostErr.ErrorCode() != ErrCodePluginNotFound {
+		t.Errorf("expected HostError with code %q, got %v", ErrCodePluginNotFound, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := client.CallContext(ctx, "config_get", map[string]any{"key": "app.name"}); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
@@ -8,15 +8,22 @@ package grpc
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"log"
 	"net/rpc"
 	"os"
 	"os/exec"
+	"runtime/debug"
+	"time"
 
 	"github.com/hashicorp/go-hclog"
 	goplugin "github.com/hashicorp/go-plugin"
 
 	"github.com/goatkit/goatflow/internal/plugin"
+	"github.com/goatkit/goatflow/internal/tracing"
+	"github.com/goatkit/goatflow/pkg/plugin/hostclient"
 )
 
 // Handshake is the shared handshake config for host and plugins.
@@ -50,11 +57,20 @@ type GKPluginInterface interface {
 	Shutdown() error
 }
 
+// ContextCaller is implemented by plugins that want the context of a call.
+// The context continues the host's trace, so host API calls made with it
+// show up under the plugin call that caused them.
+type ContextCaller interface {
+	CallContext(ctx context.Context, fn string, args json.RawMessage) (json.RawMessage, error)
+}
+
 // GKPluginPlugin is the go-plugin.Plugin implementation.
 type GKPluginPlugin struct {
 	goplugin.Plugin
 	Impl GKPluginInterface
 	Host plugin.HostAPI // For bidirectional calls
+
+	hostAPI *HostAPIRPCServer // Created by Client, named once the plugin registers
 }
 
 // Server returns the RPC server for the plugin (plugin side).
@@ -66,14 +82,33 @@ func (p *GKPluginPlugin) Server(b *goplugin.MuxBroker) (interface{}, error) {
 func (p *GKPluginPlugin) Client(b *goplugin.MuxBroker, c *rpc.Client) (interface{}, error) {
 	// Start a server for the host API that the plugin can call back to
 	hostAPIServer := &HostAPIRPCServer{Host: p.Host}
-	
+	p.hostAPI = hostAPIServer
+
 	// Get an ID for the host API server
 	id := b.NextId()
-	go b.AcceptAndServe(id, hostAPIServer)
+	go serveHostAPI(b, id, hostAPIServer)
 	
 	return &GKPluginRPCClient{client: c, broker: b, hostAPIID: id}, nil
 }
 
+// serveHostAPI serves the host API on a broker connection. Unlike
+// MuxBroker.AcceptAndServe, which registers everything as "Plugin", it
+// uses the "HostAPI" name that HostAPIRPCClient calls.
+func serveHostAPI(b *goplugin.MuxBroker, id uint32, server *HostAPIRPCServer) {
+	conn, err := b.Accept(id)
+	if err != nil {
+		log.Printf("plugin: host API accept failed: %v", err)
+		return
+	}
+	rpcServer := rpc.NewServer()
+	if err := rpcServer.RegisterName("HostAPI", server); err != nil {
+		log.Printf("plugin: host API register failed: %v", err)
+		conn.Close()
+		return
+	}
+	rpcServer.ServeConn(conn)
+}
+
 // GKPluginRPCClient is the RPC client implementation (host side).
 type GKPluginRPCClient struct {
 	client    *rpc.Client
@@ -104,12 +139,39 @@ type InitRequest struct {
 }
 
 func (c *GKPluginRPCClient) Call(fn string, args json.RawMessage) (json.RawMessage, error) {
-	req := CallRequest{Function: fn, Args: args}
+	return c.CallContext(context.Background(), fn, args)
+}
+
+// CallContext calls fn, passing the trace in ctx on to the plugin.
+func (c *GKPluginRPCClient) CallContext(ctx context.Context, fn string, args json.RawMessage) (json.RawMessage, error) {
+	req := CallRequest{Function: fn, Args: args, TraceParent: tracing.TraceParent(ctx)}
+	if deadline, ok := ctx.Deadline(); ok {
+		req.Deadline = deadline
+	}
 	var resp CallResponse
-	err := c.client.Call("Plugin.Call", req, &resp)
+	// net/rpc cannot cancel a call; stop waiting for it instead. The plugin
+	// sees the deadline on its own context.
+	call := c.client.Go("Plugin.Call", req, &resp, make(chan *rpc.Call, 1))
+	var err error
+	select {
+	case <-call.Done:
+		err = call.Error
+	case <-ctx.Done():
+		return nil, fmt.Errorf("call %s: %w", fn, ctx.Err())
+	}
+	if errors.Is(err, rpc.ErrShutdown) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, &plugin.CrashError{Runtime: plugin.CrashRuntimeGRPC, Reason: "plugin process exited", Err: err}
+	}
 	if err != nil {
 		return nil, err
 	}
+	if resp.Stack != "" {
+		return nil, &plugin.CrashError{Runtime: plugin.CrashRuntimeGRPC, Reason: resp.Error, Stack: resp.Stack}
+	}
+	if resp.Error != "" && !req.Deadline.IsZero() && !time.Now().Before(req.Deadline) {
+		// The plugin gave up at the deadline it was given
+		return nil, fmt.Errorf("call %s: %s: %w", fn, resp.Error, context.DeadlineExceeded)
+	}
 	if resp.Error != "" {
 		return nil, fmt.Errorf("%s", resp.Error)
 	}
@@ -123,14 +185,23 @@ func (c *GKPluginRPCClient) Shutdown() error {
 
 // CallRequest is the RPC request for Call.
 type CallRequest struct {
-	Function string
-	Args     json.RawMessage
+	Function    string
+	Args        json.RawMessage
+	TraceParent string    // W3C traceparent of the host's span; empty when untraced
+	Deadline    time.Time // When the host stops waiting; zero without a deadline
 }
 
 // CallResponse is the RPC response for Call.
 type CallResponse struct {
 	Result json.RawMessage
 	Error  string
+	Stack  string // Set when the plugin panicked; Error then holds the panic value
+}
+
+// HostClientReceiver is implemented by plugins that call the host API.
+// SetHostClient is called with a typed client before Init.
+type HostClientReceiver interface {
+	SetHostClient(host *hostclient.Client)
 }
 
 // GKPluginRPCServer is the RPC server implementation (plugin side).
@@ -138,6 +209,7 @@ type GKPluginRPCServer struct {
 	Impl      GKPluginInterface
 	broker    *goplugin.MuxBroker
 	hostAPI   *HostAPIRPCClient // Set after Init connects back to host
+	name      string            // Plugin name from GKRegister, sent with host calls
 }
 
 func (s *GKPluginRPCServer) GKRegister(args interface{}, resp *plugin.GKRegistration) error {
@@ -145,6 +217,7 @@ func (s *GKPluginRPCServer) GKRegister(args interface{}, resp *plugin.GKRegistra
 	if err != nil {
 		return err
 	}
+	s.name = reg.Name
 	*resp = *reg
 	return nil
 }
@@ -155,13 +228,35 @@ func (s *GKPluginRPCServer) Init(req InitRequest, resp *interface{}) error {
 		conn, err := s.broker.Dial(req.HostAPIID)
 		if err == nil {
 			s.hostAPI = NewHostAPIRPCClient(rpc.NewClient(conn))
+			s.hostAPI.plugin = s.name
 		}
 	}
+	if receiver, ok := s.Impl.(HostClientReceiver); ok && s.hostAPI != nil {
+		receiver.SetHostClient(hostclient.New(s.hostAPI))
+	}
 	return s.Impl.Init(req.Config)
 }
 
 func (s *GKPluginRPCServer) Call(req CallRequest, resp *CallResponse) error {
-	result, err := s.Impl.Call(req.Function, req.Args)
+	// A panic would kill the plugin process; report it to the host instead
+	defer func() {
+		if r := recover(); r != nil {
+			*resp = CallResponse{Error: fmt.Sprintf("panic: %v", r), Stack: string(debug.Stack())}
+		}
+	}()
+	var result json.RawMessage
+	var err error
+	if cc, ok := s.Impl.(ContextCaller); ok {
+		ctx := tracing.ContextWithTraceParent(context.Background(), req.TraceParent)
+		if !req.Deadline.IsZero() {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithDeadline(ctx, req.Deadline)
+			defer cancel()
+		}
+		result, err = cc.CallContext(ctx, req.Function, req.Args)
+	} else {
+		result, err = s.Impl.Call(req.Function, req.Args)
+	}
 	if err != nil {
 		resp.Error = err.Error()
 		return nil
@@ -183,8 +278,9 @@ func LoadGRPCPlugin(execPath string, host plugin.HostAPI) (*GRPCPlugin, error) {
 	})
 
 	// Create plugin map with host API for bidirectional calls
+	gk := &GKPluginPlugin{Host: host}
 	pluginMap := map[string]goplugin.Plugin{
-		"gkplugin": &GKPluginPlugin{Host: host},
+		"gkplugin": gk,
 	}
 
 	client := goplugin.NewClient(&goplugin.ClientConfig{
@@ -221,6 +317,15 @@ func LoadGRPCPlugin(execPath string, host plugin.HostAPI) (*GRPCPlugin, error) {
 		client.Kill()
 		return nil, fmt.Errorf("failed to get plugin registration: %w", err)
 	}
+	if reg.Name == "" {
+		client.Kill()
+		return nil, fmt.Errorf("plugin registered without a name")
+	}
+	// Host API calls from the plugin are made under the name it registered
+	// with here, whatever CallerPlugin its requests carry.
+	if gk.hostAPI != nil {
+		gk.hostAPI.setPlugin(reg.Name)
+	}
 
 	return &GRPCPlugin{
 		client:       client,
@@ -248,6 +353,9 @@ func (p *GRPCPlugin) Init(ctx context.Context, host plugin.HostAPI) error {
 
 // Call implements plugin.Plugin.
 func (p *GRPCPlugin) Call(ctx context.Context, fn string, args json.RawMessage) (json.RawMessage, error) {
+	if cc, ok := p.impl.(ContextCaller); ok {
+		return cc.CallContext(ctx, fn, args)
+	}
 	return p.impl.Call(fn, args)
 }
 
@@ -1,13 +1,19 @@
 package grpc
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"net"
+	"net/rpc"
+	"strings"
 	"testing"
+	"time"
 
 	goplugin "github.com/hashicorp/go-plugin"
 
 	"github.com/goatkit/goatflow/internal/plugin"
+	"github.com/goatkit/goatflow/internal/tracing"
 )
 
 // mockPlugin implements GKPluginInterface for testing
@@ -153,6 +159,117 @@ func TestGKPluginRPCServer_Call(t *testing.T) {
 	})
 }
 
+func TestGKPluginRPCServer_CallPanic(t *testing.T) {
+	impl := &mockPlugin{
+		name: "test",
+		callFn: func(fn string, args json.RawMessage) (json.RawMessage, error) {
+			var m map[string]string
+			m["boom"] = fn // nil map write
+			return nil, nil
+		},
+	}
+	server := rpc.NewServer()
+	if err := server.RegisterName("Plugin", &GKPluginRPCServer{Impl: impl}); err != nil {
+		t.Fatal(err)
+	}
+	hostConn, pluginConn := net.Pipe()
+	go server.ServeConn(pluginConn)
+	client := &GKPluginRPCClient{client: rpc.NewClient(hostConn)}
+	defer client.client.Close()
+
+	_, err := client.CallContext(context.Background(), "explode", json.RawMessage(`{}`))
+	var crash *plugin.CrashError
+	if !errors.As(err, &crash) {
+		t.Fatalf("expected a CrashError, got %v", err)
+	}
+	if crash.Runtime != plugin.CrashRuntimeGRPC || !strings.HasPrefix(crash.Reason, "panic: assignment to entry in nil map") {
+		t.Errorf("unexpected crash %+v", crash)
+	}
+	if !strings.Contains(crash.Stack, "runtime_server_test.go") {
+		t.Errorf("expected the plugin's stack, got %q", crash.Stack)
+	}
+
+	// The plugin survives the panic
+	impl.callFn = nil
+	if _, err := client.CallContext(context.Background(), "hello", nil); err != nil {
+		t.Errorf("call after panic failed: %v", err)
+	}
+
+	client.client.Close()
+	_, err = client.CallContext(context.Background(), "hello", nil)
+	if !errors.As(err, &crash) || crash.Reason != "plugin process exited" {
+		t.Errorf("expected a crash once the connection is gone, got %v", err)
+	}
+}
+
+// blockingPlugin waits in every call until its context is done.
+type blockingPlugin struct {
+	mockPlugin
+	deadline chan time.Time
+}
+
+func (p *blockingPlugin) CallContext(ctx context.Context, fn string, args json.RawMessage) (json.RawMessage, error) {
+	deadline, _ := ctx.Deadline()
+	p.deadline <- deadline
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestGKPluginRPCClient_CallHonorsDeadline(t *testing.T) {
+	impl := &blockingPlugin{mockPlugin: mockPlugin{name: "test"}, deadline: make(chan time.Time, 1)}
+	server := rpc.NewServer()
+	if err := server.RegisterName("Plugin", &GKPluginRPCServer{Impl: impl}); err != nil {
+		t.Fatal(err)
+	}
+	hostConn, pluginConn := net.Pipe()
+	go server.ServeConn(pluginConn)
+	client := &GKPluginRPCClient{client: rpc.NewClient(hostConn)}
+	defer client.client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := client.CallContext(ctx, "slow_report", nil)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected a deadline error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("the host kept waiting for %s", elapsed)
+	}
+	want, _ := ctx.Deadline()
+	if got := <-impl.deadline; !got.Equal(want) {
+		t.Errorf("expected the plugin context to carry the deadline %s, got %s", want, got)
+	}
+}
+
+// contextPlugin implements ContextCaller in addition to GKPluginInterface.
+type contextPlugin struct {
+	mockPlugin
+	traceParent string
+}
+
+func (p *contextPlugin) CallContext(ctx context.Context, fn string, args json.RawMessage) (json.RawMessage, error) {
+	p.traceParent = tracing.TraceParent(ctx)
+	return json.Marshal(map[string]string{"fn": fn})
+}
+
+func TestGKPluginRPCServer_CallContinuesTrace(t *testing.T) {
+	impl := &contextPlugin{mockPlugin: mockPlugin{name: "test"}}
+	server := &GKPluginRPCServer{Impl: impl}
+
+	const traceParent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	var resp CallResponse
+	if err := server.Call(CallRequest{Function: "slow_report", TraceParent: traceParent}, &resp); err != nil {
+		t.Fatalf("Call error: %v", err)
+	}
+	if resp.Error != "" {
+		t.Fatalf("unexpected error in response: %s", resp.Error)
+	}
+	if impl.traceParent != traceParent {
+		t.Errorf("expected plugin context to carry %q, got %q", traceParent, impl.traceParent)
+	}
+}
+
 func TestGKPluginRPCServer_Shutdown(t *testing.T) {
 	impl := &mockPlugin{name: "test"}
 	server := &GKPluginRPCServer{Impl: impl}
@@ -23,13 +23,23 @@ func (m *mockHostAPI) DBQuery(ctx context.Context, query string, args ...any) ([
 func (m *mockHostAPI) DBExec(ctx context.Context, query string, args ...any) (int64, error) {
 	return 1, nil
 }
+func (m *mockHostAPI) DBBegin(ctx context.Context, dbName string) (string, error) {
+	return "tx-1", nil
+}
+func (m *mockHostAPI) DBCommit(ctx context.Context, txID string) error   { return nil }
+func (m *mockHostAPI) DBRollback(ctx context.Context, txID string) error { return nil }
 func (m *mockHostAPI) CacheGet(ctx context.Context, key string) ([]byte, bool, error) {
 	return nil, false, nil
 }
 func (m *mockHostAPI) CacheSet(ctx context.Context, key string, value []byte, ttlSeconds int) error {
 	return nil
 }
-func (m *mockHostAPI) CacheDelete(ctx context.Context, key string) error { return nil }
+func (m *mockHostAPI) CacheDelete(ctx context.Context, key string) error              { return nil }
+func (m *mockHostAPI) FilePut(ctx context.Context, path string, content []byte) error { return nil }
+func (m *mockHostAPI) FileGet(ctx context.Context, path string) ([]byte, bool, error) {
+	return nil, false, nil
+}
+func (m *mockHostAPI) FileDelete(ctx context.Context, path string) error { return nil }
 func (m *mockHostAPI) HTTPRequest(ctx context.Context, method, url string, headers map[string]string, body []byte) (int, []byte, error) {
 	return 200, []byte(`{"ok":true}`), nil
 }
@@ -40,6 +50,9 @@ func (m *mockHostAPI) Log(ctx context.Context, level, message string, fields map
 func (m *mockHostAPI) ConfigGet(ctx context.Context, key string) (string, error) {
 	return "", nil
 }
+func (m *mockHostAPI) FeatureEnabled(ctx context.Context, flag string, userID int) (bool, error) {
+	return false, nil
+}
 func (m *mockHostAPI) Translate(ctx context.Context, key string, args ...any) string { return key }
 func (m *mockHostAPI) CallPlugin(ctx context.Context, pluginName, function string, args json.RawMessage) (json.RawMessage, error) {
 	return json.Marshal(map[string]string{"result": "ok"})
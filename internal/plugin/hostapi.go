@@ -30,6 +30,21 @@ func (h *DefaultHostAPI) DBExec(ctx context.Context, query string, args ...any)
 	return 0, nil
 }
 
+// DBBegin starts a transaction.
+func (h *DefaultHostAPI) DBBegin(ctx context.Context, dbName string) (string, error) {
+	return "", fmt.Errorf("transactions not available in default host")
+}
+
+// DBCommit commits a transaction.
+func (h *DefaultHostAPI) DBCommit(ctx context.Context, txID string) error {
+	return fmt.Errorf("transactions not available in default host")
+}
+
+// DBRollback rolls back a transaction.
+func (h *DefaultHostAPI) DBRollback(ctx context.Context, txID string) error {
+	return fmt.Errorf("transactions not available in default host")
+}
+
 // CacheGet retrieves a value from cache.
 func (h *DefaultHostAPI) CacheGet(ctx context.Context, key string) ([]byte, bool, error) {
 	// TODO: Wire to Valkey/Redis
@@ -48,6 +63,21 @@ func (h *DefaultHostAPI) CacheDelete(ctx context.Context, key string) error {
 	return nil
 }
 
+// FilePut stores a file; the default host has no file storage.
+func (h *DefaultHostAPI) FilePut(ctx context.Context, path string, content []byte) error {
+	return ErrFileStorageUnavailable
+}
+
+// FileGet reads a stored file; the default host has none.
+func (h *DefaultHostAPI) FileGet(ctx context.Context, path string) ([]byte, bool, error) {
+	return nil, false, nil
+}
+
+// FileDelete removes a stored file; the default host has none.
+func (h *DefaultHostAPI) FileDelete(ctx context.Context, path string) error {
+	return nil
+}
+
 // HTTPRequest makes an outbound HTTP request.
 func (h *DefaultHostAPI) HTTPRequest(ctx context.Context, method, url string, headers map[string]string, body []byte) (int, []byte, error) {
 	// TODO: Implement HTTP client
@@ -71,6 +101,12 @@ func (h *DefaultHostAPI) ConfigGet(ctx context.Context, key string) (string, err
 	return "", nil
 }
 
+// FeatureEnabled reports whether a feature flag is on; no flags are on in
+// the default host.
+func (h *DefaultHostAPI) FeatureEnabled(ctx context.Context, flag string, userID int) (bool, error) {
+	return false, nil
+}
+
 // Translate translates a key to the current locale.
 func (h *DefaultHostAPI) Translate(ctx context.Context, key string, args ...any) string {
 	// TODO: Wire to i18n system
@@ -0,0 +1,155 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// ErrCacheValueTooLarge is returned by CacheSet for values larger than the
+// plugin's ResourcePolicy.MaxCacheValueBytes.
+var ErrCacheValueTooLarge = errors.New("cache value exceeds the plugin's size limit")
+
+// cacheKey namespaces key with the calling plugin, so plugins cannot read or
+// overwrite each other's entries. Calls without a known caller share the
+// "host" namespace.
+func cacheKey(ctx context.Context, key string) (pluginName, namespaced string) {
+	pluginName = callerPlugin(ctx)
+	if pluginName == "" {
+		pluginName = "host"
+	}
+	return pluginName, pluginName + ":" + key
+}
+
+// CacheGet retrieves a value from the calling plugin's cache.
+func (h *ProdHostAPI) CacheGet(ctx context.Context, key string) (_ []byte, _ bool, err error) {
+	if h.cache == nil {
+		return nil, false, nil // No cache configured, return miss
+	}
+	ctx, span := startSpan(ctx, "cache_get")
+	defer func() { span.Finish(err) }()
+
+	name, k := cacheKey(ctx, key)
+	val, found, err := h.cache.Get(ctx, k)
+	if err != nil {
+		// An unreachable cache is a miss; the plugin recomputes the value
+		pluginCacheMetricsFor().observe(name, "error")
+		h.logger.Warn("plugin cache read failed", "plugin", name, "error", err)
+		return nil, false, nil
+	}
+	if found {
+		pluginCacheMetricsFor().observe(name, "hit")
+	} else {
+		pluginCacheMetricsFor().observe(name, "miss")
+	}
+	return val, found, nil
+}
+
+// CacheSet stores a value in the calling plugin's cache. A ttl of 0 keeps
+// the value until it is evicted.
+func (h *ProdHostAPI) CacheSet(ctx context.Context, key string, value []byte, ttlSeconds int) (err error) {
+	if h.cache == nil {
+		return nil // No cache configured, silently succeed
+	}
+	ctx, span := startSpan(ctx, "cache_set")
+	defer func() { span.Finish(err) }()
+
+	name, k := cacheKey(ctx, key)
+	if limit := h.ResourcePolicyFor(name).MaxCacheValueBytes; len(value) > limit {
+		return fmt.Errorf("%w (%d > %d bytes)", ErrCacheValueTooLarge, len(value), limit)
+	}
+	if ttlSeconds < 0 {
+		ttlSeconds = 0
+	}
+	if err := h.cache.Set(ctx, k, value, time.Duration(ttlSeconds)*time.Second); err != nil {
+		pluginCacheMetricsFor().observe(name, "error")
+		return fmt.Errorf("cache set: %w", err)
+	}
+	pluginCacheMetricsFor().observe(name, "set")
+	return nil
+}
+
+// CacheDelete removes a value from the calling plugin's cache.
+func (h *ProdHostAPI) CacheDelete(ctx context.Context, key string) (err error) {
+	if h.cache == nil {
+		return nil // No cache configured, silently succeed
+	}
+	ctx, span := startSpan(ctx, "cache_delete")
+	defer func() { span.Finish(err) }()
+
+	name, k := cacheKey(ctx, key)
+	if err := h.cache.Delete(ctx, k); err != nil {
+		pluginCacheMetricsFor().observe(name, "error")
+		return fmt.Errorf("cache delete: %w", err)
+	}
+	pluginCacheMetricsFor().observe(name, "delete")
+	return nil
+}
+
+type pluginCacheMetrics struct {
+	operations *prometheus.CounterVec
+	evictions  *prometheus.CounterVec
+}
+
+var (
+	pluginCacheMetricsOnce sync.Once
+	pluginCacheMetricsInst *pluginCacheMetrics
+)
+
+func pluginCacheMetricsFor() *pluginCacheMetrics {
+	pluginCacheMetricsOnce.Do(func() {
+		pluginCacheMetricsInst = &pluginCacheMetrics{
+			operations: promauto.NewCounterVec(prometheus.CounterOpts{
+				Namespace: "goatflow",
+				Subsystem: "plugin_cache",
+				Name:      "operations_total",
+				Help:      "Plugin cache operations, labeled by plugin and result (hit, miss, set, delete or error)",
+			}, []string{"plugin", "result"}),
+			evictions: promauto.NewCounterVec(prometheus.CounterOpts{
+				Namespace: "goatflow",
+				Subsystem: "plugin_cache",
+				Name:      "evictions_total",
+				Help:      "Plugin cache values evicted from the in-memory store to make room, labeled by plugin",
+			}, []string{"plugin"}),
+		}
+	})
+	return pluginCacheMetricsInst
+}
+
+func (m *pluginCacheMetrics) observe(pluginName, result string) {
+	m.operations.WithLabelValues(pluginName, result).Inc()
+}
+
+// RegisterCacheStoreMetrics reports evictions and the size of store as
+// Prometheus metrics. Call it once for the store passed to WithCache.
+func RegisterCacheStoreMetrics(store *MemoryCacheStore) {
+	m := pluginCacheMetricsFor()
+	store.OnEvict(func(key string) {
+		name, _, _ := strings.Cut(key, ":")
+		m.evictions.WithLabelValues(name).Inc()
+	})
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: "goatflow",
+		Subsystem: "plugin_cache",
+		Name:      "entries",
+		Help:      "Values held by the in-memory plugin cache",
+	}, func() float64 {
+		n, _ := store.Len()
+		return float64(n)
+	})
+	promauto.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: "goatflow",
+		Subsystem: "plugin_cache",
+		Name:      "bytes",
+		Help:      "Size of the keys and values held by the in-memory plugin cache",
+	}, func() float64 {
+		_, b := store.Len()
+		return float64(b)
+	})
+}
@@ -0,0 +1,105 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMemoryCacheStore_EvictsLeastRecentlyUsed(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryCacheStore(2, 0)
+	var evicted []string
+	s.OnEvict(func(key string) { evicted = append(evicted, key) })
+
+	_ = s.Set(ctx, "a", []byte("1"), 0)
+	_ = s.Set(ctx, "b", []byte("2"), 0)
+	if _, ok, _ := s.Get(ctx, "a"); !ok {
+		t.Fatal("a should be cached")
+	}
+	_ = s.Set(ctx, "c", []byte("3"), 0)
+
+	if _, ok, _ := s.Get(ctx, "b"); ok {
+		t.Error("b was least recently used and should be evicted")
+	}
+	if _, ok, _ := s.Get(ctx, "a"); !ok {
+		t.Error("a was read recently and should stay")
+	}
+	if len(evicted) != 1 || evicted[0] != "b" {
+		t.Errorf("evicted = %v, want [b]", evicted)
+	}
+}
+
+func TestMemoryCacheStore_ByteLimit(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryCacheStore(0, 9)
+
+	_ = s.Set(ctx, "a", []byte("1234"), 0)
+	_ = s.Set(ctx, "b", []byte("1234"), 0)
+	if n, size := s.Len(); n != 1 || size != 5 {
+		t.Errorf("Len() = %d, %d; want 1 entry of 5 bytes", n, size)
+	}
+	if err := s.Set(ctx, "big", make([]byte, 20), 0); err == nil {
+		t.Error("a value larger than the store should be rejected")
+	}
+}
+
+func TestMemoryCacheStore_TTL(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryCacheStore(0, 0)
+
+	_ = s.Set(ctx, "short", []byte("x"), time.Millisecond)
+	_ = s.Set(ctx, "forever", []byte("y"), 0)
+	time.Sleep(5 * time.Millisecond)
+
+	if _, ok, _ := s.Get(ctx, "short"); ok {
+		t.Error("expired value should miss")
+	}
+	if _, ok, _ := s.Get(ctx, "forever"); !ok {
+		t.Error("value without TTL should stay")
+	}
+	if n, _ := s.Len(); n != 1 {
+		t.Errorf("expired value should be dropped on read, %d entries left", n)
+	}
+}
+
+func TestProdHostAPI_CacheNamespacedPerPlugin(t *testing.T) {
+	h := NewProdHostAPI(WithCache(NewMemoryCacheStore(0, 0)))
+	stats := context.WithValue(context.Background(), PluginCallerKey, "stats")
+	other := context.WithValue(context.Background(), PluginCallerKey, "other")
+
+	if err := h.CacheSet(stats, "count", []byte("42"), 60); err != nil {
+		t.Fatalf("CacheSet: %v", err)
+	}
+	val, found, err := h.CacheGet(stats, "count")
+	if err != nil || !found || !bytes.Equal(val, []byte("42")) {
+		t.Fatalf("CacheGet = %q, %v, %v; want 42", val, found, err)
+	}
+	if _, found, _ := h.CacheGet(other, "count"); found {
+		t.Error("another plugin must not see the value")
+	}
+
+	if err := h.CacheDelete(other, "count"); err != nil {
+		t.Fatalf("CacheDelete: %v", err)
+	}
+	if _, found, _ := h.CacheGet(stats, "count"); !found {
+		t.Error("another plugin must not delete the value")
+	}
+}
+
+func TestProdHostAPI_CacheValueLimit(t *testing.T) {
+	h := NewProdHostAPI(
+		WithCache(NewMemoryCacheStore(0, 0)),
+		WithPluginResourcePolicy("stats", ResourcePolicy{MaxCacheValueBytes: 4}),
+	)
+	ctx := context.WithValue(context.Background(), PluginCallerKey, "stats")
+
+	if err := h.CacheSet(ctx, "k", []byte("12345"), 0); !errors.Is(err, ErrCacheValueTooLarge) {
+		t.Errorf("err = %v, want ErrCacheValueTooLarge", err)
+	}
+	if err := h.CacheSet(ctx, "k", []byte("1234"), 0); err != nil {
+		t.Errorf("value at the limit should be stored: %v", err)
+	}
+}
@@ -0,0 +1,140 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// FileStore holds the files plugins keep through the FilePut, FileGet and
+// FileDelete host functions. The host namespaces paths per plugin, under
+// plugins/<name>/, before they reach the store.
+type FileStore interface {
+	// Put stores content at path, replacing an existing file.
+	Put(ctx context.Context, path string, content []byte) error
+	// Get returns the content of path, or false when it does not exist.
+	Get(ctx context.Context, path string) ([]byte, bool, error)
+	// Delete removes path. Deleting a missing file is not an error.
+	Delete(ctx context.Context, path string) error
+	// Size returns the size of the file at path, or the total size of the
+	// files under it when it is a directory; 0 when it does not exist.
+	Size(ctx context.Context, path string) (int64, error)
+}
+
+// File errors returned by the host API.
+var (
+	ErrFileStorageUnavailable = errors.New("file storage is not configured")
+	ErrInvalidFilePath        = errors.New("invalid file path")
+	ErrFileTooLarge           = errors.New("file exceeds the plugin's size limit")
+	ErrFileQuotaExceeded      = errors.New("plugin file storage quota exceeded")
+	ErrNoCallingPlugin        = errors.New("file access without a calling plugin")
+)
+
+// maxFilePathLen bounds the paths plugins store files under.
+const maxFilePathLen = 255
+
+// fileRoot is the directory holding the calling plugin's files. Calls
+// without a known caller are refused.
+func fileRoot(ctx context.Context) (pluginName, root string, err error) {
+	pluginName = callerPlugin(ctx)
+	if pluginName == "" {
+		return "", "", ErrNoCallingPlugin
+	}
+	if pluginName == "." || pluginName == ".." || strings.ContainsAny(pluginName, "/\\\x00") {
+		return "", "", fmt.Errorf("%w: plugin name %q", ErrInvalidFilePath, pluginName)
+	}
+	return pluginName, "plugins/" + pluginName, nil
+}
+
+// filePath namespaces a plugin's relative file path. Paths must be clean
+// and stay inside the plugin's directory, e.g. "exports/2024.csv".
+func filePath(ctx context.Context, p string) (pluginName, root, namespaced string, err error) {
+	if p == "" || len(p) > maxFilePathLen || path.Clean(p) != p || path.IsAbs(p) ||
+		p == "." || p == ".." || strings.HasPrefix(p, "../") || strings.ContainsAny(p, "\\\x00") {
+		return "", "", "", fmt.Errorf("%w: %q", ErrInvalidFilePath, p)
+	}
+	pluginName, root, err = fileRoot(ctx)
+	if err != nil {
+		return "", "", "", err
+	}
+	return pluginName, root, root + "/" + p, nil
+}
+
+// FilePut stores a file for the calling plugin, replacing an existing one.
+// The file and the plugin's files together must stay within its
+// ResourcePolicy.MaxFileBytes and MaxStorageBytes.
+func (h *ProdHostAPI) FilePut(ctx context.Context, p string, content []byte) (err error) {
+	if h.files == nil {
+		return ErrFileStorageUnavailable
+	}
+	ctx, span := startSpan(ctx, "file_put")
+	defer func() { span.Finish(err) }()
+
+	name, root, key, err := filePath(ctx, p)
+	if err != nil {
+		return err
+	}
+	policy := h.ResourcePolicyFor(name)
+	size := int64(len(content))
+	if size > policy.MaxFileBytes {
+		return fmt.Errorf("%w (%d > %d bytes)", ErrFileTooLarge, size, policy.MaxFileBytes)
+	}
+
+	h.filesMu.Lock()
+	defer h.filesMu.Unlock()
+	used, err := h.files.Size(ctx, root)
+	if err != nil {
+		return fmt.Errorf("file put: %w", err)
+	}
+	replaced, err := h.files.Size(ctx, key)
+	if err != nil {
+		return fmt.Errorf("file put: %w", err)
+	}
+	if used-replaced+size > policy.MaxStorageBytes {
+		return fmt.Errorf("%w (%d of %d bytes used)", ErrFileQuotaExceeded, used, policy.MaxStorageBytes)
+	}
+	if err := h.files.Put(ctx, key, content); err != nil {
+		return fmt.Errorf("file put: %w", err)
+	}
+	return nil
+}
+
+// FileGet reads a file of the calling plugin; found is false when it does
+// not exist.
+func (h *ProdHostAPI) FileGet(ctx context.Context, p string) (_ []byte, _ bool, err error) {
+	if h.files == nil {
+		return nil, false, nil
+	}
+	ctx, span := startSpan(ctx, "file_get")
+	defer func() { span.Finish(err) }()
+
+	_, _, key, err := filePath(ctx, p)
+	if err != nil {
+		return nil, false, err
+	}
+	content, found, err := h.files.Get(ctx, key)
+	if err != nil {
+		return nil, false, fmt.Errorf("file get: %w", err)
+	}
+	return content, found, nil
+}
+
+// FileDelete removes a file of the calling plugin.
+func (h *ProdHostAPI) FileDelete(ctx context.Context, p string) (err error) {
+	if h.files == nil {
+		return nil
+	}
+	ctx, span := startSpan(ctx, "file_delete")
+	defer func() { span.Finish(err) }()
+
+	_, _, key, err := filePath(ctx, p)
+	if err != nil {
+		return err
+	}
+	if err := h.files.Delete(ctx, key); err != nil {
+		return fmt.Errorf("file delete: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// memFileStore is a FileStore in a map, sized like a directory tree.
+type memFileStore map[string][]byte
+
+func (s memFileStore) Put(_ context.Context, path string, content []byte) error {
+	s[path] = content
+	return nil
+}
+
+func (s memFileStore) Get(_ context.Context, path string) ([]byte, bool, error) {
+	v, ok := s[path]
+	return v, ok, nil
+}
+
+func (s memFileStore) Delete(_ context.Context, path string) error {
+	delete(s, path)
+	return nil
+}
+
+func (s memFileStore) Size(_ context.Context, path string) (int64, error) {
+	var n int64
+	for k, v := range s {
+		if k == path || strings.HasPrefix(k, path+"/") {
+			n += int64(len(v))
+		}
+	}
+	return n, nil
+}
+
+func TestProdHostAPI_FilesNamespacedPerPlugin(t *testing.T) {
+	store := memFileStore{}
+	h := NewProdHostAPI(WithFileStore(store))
+	stats := context.WithValue(context.Background(), PluginCallerKey, "stats")
+	other := context.WithValue(context.Background(), PluginCallerKey, "other")
+
+	if err := h.FilePut(stats, "exports/report.csv", []byte("a,b")); err != nil {
+		t.Fatalf("FilePut: %v", err)
+	}
+	if _, ok := store["plugins/stats/exports/report.csv"]; !ok {
+		t.Errorf("expected the file under the plugin's directory, store has %v", store)
+	}
+	if content, found, err := h.FileGet(stats, "exports/report.csv"); err != nil || !found || string(content) != "a,b" {
+		t.Errorf("FileGet = %q, %v, %v", content, found, err)
+	}
+	if _, found, _ := h.FileGet(other, "exports/report.csv"); found {
+		t.Error("another plugin should not see the file")
+	}
+	if err := h.FileDelete(other, "exports/report.csv"); err != nil {
+		t.Fatalf("FileDelete: %v", err)
+	}
+	if _, found, _ := h.FileGet(stats, "exports/report.csv"); !found {
+		t.Error("another plugin should not delete the file")
+	}
+	if err := h.FileDelete(stats, "exports/report.csv"); err != nil {
+		t.Fatalf("FileDelete: %v", err)
+	}
+	if _, found, _ := h.FileGet(stats, "exports/report.csv"); found {
+		t.Error("expected the file to be deleted")
+	}
+}
+
+func TestProdHostAPI_FilePathValidation(t *testing.T) {
+	h := NewProdHostAPI(WithFileStore(memFileStore{}))
+	ctx := context.WithValue(context.Background(), PluginCallerKey, "stats")
+	for _, p := range []string{"", "/etc/passwd", "../other/x", "a/../../x", "a//b", "./a", "a\\b", strings.Repeat("a", 256)} {
+		if err := h.FilePut(ctx, p, []byte("x")); !errors.Is(err, ErrInvalidFilePath) {
+			t.Errorf("FilePut(%q) error = %v, want ErrInvalidFilePath", p, err)
+		}
+	}
+	evil := context.WithValue(context.Background(), PluginCallerKey, "..")
+	if err := h.FilePut(evil, "x", []byte("x")); !errors.Is(err, ErrInvalidFilePath) {
+		t.Errorf("expected a plugin named .. to be refused, got %v", err)
+	}
+	if err := h.FilePut(context.Background(), "x", []byte("x")); !errors.Is(err, ErrNoCallingPlugin) {
+		t.Errorf("expected a call without a caller to be refused, got %v", err)
+	}
+}
+
+func TestProdHostAPI_FileQuota(t *testing.T) {
+	h := NewProdHostAPI(WithFileStore(memFileStore{}),
+		WithPluginResourcePolicy("stats", ResourcePolicy{MaxFileBytes: 4, MaxStorageBytes: 6}))
+	ctx := context.WithValue(context.Background(), PluginCallerKey, "stats")
+
+	if err := h.FilePut(ctx, "big", []byte("12345")); !errors.Is(err, ErrFileTooLarge) {
+		t.Errorf("expected ErrFileTooLarge, got %v", err)
+	}
+	if err := h.FilePut(ctx, "a", []byte("1234")); err != nil {
+		t.Fatalf("FilePut: %v", err)
+	}
+	if err := h.FilePut(ctx, "b", []byte("123")); !errors.Is(err, ErrFileQuotaExceeded) {
+		t.Errorf("expected ErrFileQuotaExceeded, got %v", err)
+	}
+	if err := h.FilePut(ctx, "a", []byte("12")); err != nil {
+		t.Errorf("replacing a file should only count its new size: %v", err)
+	}
+	if err := h.FilePut(ctx, "b", []byte("1234")); err != nil {
+		t.Errorf("expected room after shrinking a: %v", err)
+	}
+
+	other := context.WithValue(context.Background(), PluginCallerKey, "other")
+	if err := h.FilePut(other, "a", []byte("1234")); err != nil {
+		t.Errorf("another plugin has its own quota: %v", err)
+	}
+}
+
+func TestProdHostAPI_FilesWithoutStore(t *testing.T) {
+	h := NewProdHostAPI()
+	ctx := context.Background()
+	if err := h.FilePut(ctx, "a", []byte("x")); !errors.Is(err, ErrFileStorageUnavailable) {
+		t.Errorf("expected ErrFileStorageUnavailable, got %v", err)
+	}
+	if _, found, err := h.FileGet(ctx, "a"); found || err != nil {
+		t.Errorf("FileGet = %v, %v; want a miss", found, err)
+	}
+}
@@ -9,13 +9,14 @@ import (
 	"io"
 	"log/slog"
 	"net/http"
+	"sync"
 	"time"
 
-	"github.com/goatkit/goatflow/internal/cache"
 	"github.com/goatkit/goatflow/internal/config"
 	"github.com/goatkit/goatflow/internal/database"
 	"github.com/goatkit/goatflow/internal/i18n"
 	"github.com/goatkit/goatflow/internal/notifications"
+	"github.com/goatkit/goatflow/internal/tracing"
 )
 
 // PluginLanguageKey is the context key for plugin request language.
@@ -35,10 +36,19 @@ var PluginLanguageKey = pluginLangKeyType{}
 type ProdHostAPI struct {
 	databases     map[string]*sql.DB // Named database connections
 	defaultDB     string             // Name of the default database
-	cache         *cache.RedisCache
+	cache         CacheStore
 	httpClient    *http.Client
 	logger        *slog.Logger
 	PluginManager *Manager // For plugin-to-plugin calls
+	featureFlags  FeatureFlags
+	files         FileStore
+	filesMu       sync.Mutex // Serializes FilePut so quota checks see earlier writes
+
+	// Resource policies and open plugin transactions, guarded by txMu
+	txMu          sync.Mutex
+	defaultPolicy ResourcePolicy
+	policies      map[string]ResourcePolicy
+	txs           map[string]*pluginTx
 }
 
 // ProdHostAPIOption is a functional option for ProdHostAPI.
@@ -65,8 +75,9 @@ func WithDefaultDB(name string) ProdHostAPIOption {
 	}
 }
 
-// WithCache sets the cache client.
-func WithCache(c *cache.RedisCache) ProdHostAPIOption {
+// WithCache sets the store behind the plugin cache functions. Without one,
+// CacheGet always misses and CacheSet discards the value.
+func WithCache(c CacheStore) ProdHostAPIOption {
 	return func(h *ProdHostAPI) {
 		h.cache = c
 	}
@@ -86,15 +97,59 @@ func WithPluginManager(mgr *Manager) ProdHostAPIOption {
 	}
 }
 
+// WithResourcePolicy sets the resource policy for plugins without their own.
+func WithResourcePolicy(policy ResourcePolicy) ProdHostAPIOption {
+	return func(h *ProdHostAPI) {
+		h.defaultPolicy = policy
+	}
+}
+
+// WithPluginResourcePolicy sets the resource policy for a single plugin.
+func WithPluginResourcePolicy(pluginName string, policy ResourcePolicy) ProdHostAPIOption {
+	return func(h *ProdHostAPI) {
+		if h.policies == nil {
+			h.policies = make(map[string]ResourcePolicy)
+		}
+		h.policies[pluginName] = policy
+	}
+}
+
+// FeatureFlags evaluates the host's feature flags for the feature_enabled
+// host function.
+type FeatureFlags interface {
+	Enabled(ctx context.Context, flag string, userID int) (bool, error)
+}
+
+// WithFeatureFlags sets the feature flag evaluator. Without one every flag
+// is off.
+func WithFeatureFlags(flags FeatureFlags) ProdHostAPIOption {
+	return func(h *ProdHostAPI) {
+		h.featureFlags = flags
+	}
+}
+
+// WithFileStore sets the store behind the plugin file functions. Without
+// one, FilePut fails with ErrFileStorageUnavailable and FileGet finds
+// nothing.
+func WithFileStore(s FileStore) ProdHostAPIOption {
+	return func(h *ProdHostAPI) {
+		h.files = s
+	}
+}
+
 // NewProdHostAPI creates a production host API with the given options.
 func NewProdHostAPI(opts ...ProdHostAPIOption) *ProdHostAPI {
 	h := &ProdHostAPI{
 		databases: make(map[string]*sql.DB),
 		defaultDB: "default",
 		httpClient: &http.Client{
-			Timeout: 30 * time.Second,
+			Timeout:   30 * time.Second,
+			Transport: tracing.NewTransport(nil),
 		},
-		logger: slog.Default(),
+		logger:        slog.Default(),
+		defaultPolicy: DefaultResourcePolicy(),
+		policies:      make(map[string]ResourcePolicy),
+		txs:           make(map[string]*pluginTx),
 	}
 	for _, opt := range opts {
 		opt(h)
@@ -129,6 +184,14 @@ func (h *ProdHostAPI) parseDBPrefix(query string) (dbName, cleanQuery string) {
 	return "", query
 }
 
+// dbNameOrDefault returns name, or def when name is empty.
+func dbNameOrDefault(name, def string) string {
+	if name == "" {
+		return def
+	}
+	return name
+}
+
 // indexByte returns the index of the first instance of c in s, or -1 if not present.
 func indexByte(s string, c byte) int {
 	for i := 0; i < len(s); i++ {
@@ -139,11 +202,26 @@ func indexByte(s string, c byte) int {
 	return -1
 }
 
+// startSpan starts a span for a host API call, tagged with the calling
+// plugin. Its children are the SQL statements or HTTP requests it makes.
+func startSpan(ctx context.Context, op string, attrs ...tracing.Attribute) (context.Context, *tracing.Span) {
+	if caller := callerPlugin(ctx); caller != "" {
+		attrs = append(attrs, tracing.String("plugin.name", caller))
+	}
+	return tracing.Start(ctx, "hostapi."+op, attrs...)
+}
+
 // DBQuery executes a SELECT query and returns rows as maps.
 // Uses the default database. For named databases, prefix query with "@dbname:" (e.g., "@analytics:SELECT...").
-func (h *ProdHostAPI) DBQuery(ctx context.Context, query string, args ...any) ([]map[string]any, error) {
+// Runs inside the transaction named by the context (see WithTxID), if any.
+func (h *ProdHostAPI) DBQuery(ctx context.Context, query string, args ...any) (_ []map[string]any, err error) {
 	dbName, query := h.parseDBPrefix(query)
-	db, err := h.getDB(dbName)
+	ctx, span := startSpan(ctx, "db_query", tracing.String("db.namespace", dbNameOrDefault(dbName, h.defaultDB)))
+	defer func() { span.Finish(err) }()
+	if callerPlugin(ctx) != "" && isDDL(query) {
+		return nil, ErrDDLNotAllowed
+	}
+	db, err := h.runnerFor(ctx, dbName)
 	if err != nil {
 		return nil, err
 	}
@@ -200,9 +278,16 @@ func (h *ProdHostAPI) DBQuery(ctx context.Context, query string, args ...any) ([
 
 // DBExec executes an INSERT/UPDATE/DELETE and returns affected rows.
 // Uses the default database. For named databases, prefix query with "@dbname:" (e.g., "@analytics:INSERT...").
-func (h *ProdHostAPI) DBExec(ctx context.Context, query string, args ...any) (int64, error) {
+// Runs inside the transaction named by the context (see WithTxID), if any.
+// Plugins may not change the schema here; they declare migrations instead.
+func (h *ProdHostAPI) DBExec(ctx context.Context, query string, args ...any) (_ int64, err error) {
 	dbName, query := h.parseDBPrefix(query)
-	db, err := h.getDB(dbName)
+	ctx, span := startSpan(ctx, "db_exec", tracing.String("db.namespace", dbNameOrDefault(dbName, h.defaultDB)))
+	defer func() { span.Finish(err) }()
+	if callerPlugin(ctx) != "" && isDDL(query) {
+		return 0, ErrDDLNotAllowed
+	}
+	db, err := h.runnerFor(ctx, dbName)
 	if err != nil {
 		return 0, err
 	}
@@ -223,54 +308,11 @@ func (h *ProdHostAPI) DBExec(ctx context.Context, query string, args ...any) (in
 	return affected, nil
 }
 
-// CacheGet retrieves a value from cache.
-func (h *ProdHostAPI) CacheGet(ctx context.Context, key string) ([]byte, bool, error) {
-	if h.cache == nil {
-		return nil, false, nil // No cache configured, return miss
-	}
-
-	val, err := h.cache.Get(ctx, key)
-	if err != nil {
-		// Cache miss or error - treat as miss
-		return nil, false, nil
-	}
-
-	if val == nil {
-		return nil, false, nil
-	}
-
-	// Convert to bytes
-	switch v := val.(type) {
-	case []byte:
-		return v, true, nil
-	case string:
-		return []byte(v), true, nil
-	default:
-		return nil, false, fmt.Errorf("unexpected cache value type: %T", val)
-	}
-}
-
-// CacheSet stores a value in cache.
-func (h *ProdHostAPI) CacheSet(ctx context.Context, key string, value []byte, ttlSeconds int) error {
-	if h.cache == nil {
-		return nil // No cache configured, silently succeed
-	}
-
-	ttl := time.Duration(ttlSeconds) * time.Second
-	return h.cache.Set(ctx, key, value, ttl)
-}
-
-// CacheDelete removes a value from cache.
-func (h *ProdHostAPI) CacheDelete(ctx context.Context, key string) error {
-	if h.cache == nil {
-		return nil // No cache configured, silently succeed
-	}
-
-	return h.cache.Delete(ctx, key)
-}
-
 // HTTPRequest makes an outbound HTTP request.
-func (h *ProdHostAPI) HTTPRequest(ctx context.Context, method, url string, headers map[string]string, body []byte) (int, []byte, error) {
+func (h *ProdHostAPI) HTTPRequest(ctx context.Context, method, url string, headers map[string]string, body []byte) (_ int, _ []byte, err error) {
+	ctx, span := startSpan(ctx, "http_request", tracing.String("http.request.method", method))
+	defer func() { span.Finish(err) }()
+
 	var bodyReader io.Reader
 	if body != nil {
 		bodyReader = bytes.NewReader(body)
@@ -300,7 +342,10 @@ func (h *ProdHostAPI) HTTPRequest(ctx context.Context, method, url string, heade
 }
 
 // SendEmail sends an email using the configured provider.
-func (h *ProdHostAPI) SendEmail(ctx context.Context, to, subject, body string, html bool) error {
+func (h *ProdHostAPI) SendEmail(ctx context.Context, to, subject, body string, html bool) (err error) {
+	ctx, span := startSpan(ctx, "send_email")
+	defer func() { span.Finish(err) }()
+
 	provider := notifications.GetEmailProvider()
 	if provider == nil {
 		return fmt.Errorf("email provider not configured")
@@ -368,6 +413,14 @@ func (h *ProdHostAPI) ConfigGet(ctx context.Context, key string) (string, error)
 	}
 }
 
+// FeatureEnabled reports whether a feature flag is on for a user.
+func (h *ProdHostAPI) FeatureEnabled(ctx context.Context, flag string, userID int) (bool, error) {
+	if h.featureFlags == nil {
+		return false, nil
+	}
+	return h.featureFlags.Enabled(ctx, flag, userID)
+}
+
 // Translate translates a key to the current locale.
 // The language is determined from the context (set via PluginLanguageKey).
 // If no language is set, falls back to the default language.
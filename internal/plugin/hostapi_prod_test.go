@@ -519,6 +519,30 @@ func TestProdHostAPI_CallPlugin(t *testing.T) {
 	})
 }
 
+// staticFlags turns on the flags it lists, for every user.
+type staticFlags map[string]bool
+
+func (f staticFlags) Enabled(ctx context.Context, flag string, userID int) (bool, error) {
+	return f[flag], nil
+}
+
+func TestProdHostAPI_FeatureEnabled(t *testing.T) {
+	ctx := context.Background()
+
+	on, err := NewProdHostAPI().FeatureEnabled(ctx, "beta", 1)
+	if err != nil || on {
+		t.Errorf("expected flags off without an evaluator, got %v, %v", on, err)
+	}
+
+	h := NewProdHostAPI(WithFeatureFlags(staticFlags{"beta": true}))
+	if on, _ := h.FeatureEnabled(ctx, "beta", 1); !on {
+		t.Error("expected beta on")
+	}
+	if on, _ := h.FeatureEnabled(ctx, "other", 1); on {
+		t.Error("expected other off")
+	}
+}
+
 func TestProdHostAPI_Translate(t *testing.T) {
 	h := NewProdHostAPI()
 	ctx := context.Background()
@@ -138,6 +138,14 @@ func (h *testHostAPI) DBExec(ctx context.Context, query string, args ...any) (in
 	return 1, nil
 }
 
+func (h *testHostAPI) DBBegin(ctx context.Context, dbName string) (string, error) {
+	return "tx-1", nil
+}
+
+func (h *testHostAPI) DBCommit(ctx context.Context, txID string) error { return nil }
+
+func (h *testHostAPI) DBRollback(ctx context.Context, txID string) error { return nil }
+
 func (h *testHostAPI) CacheGet(ctx context.Context, key string) ([]byte, bool, error) {
 	v, ok := h.cacheStore[key]
 	return v, ok, nil
@@ -153,6 +161,18 @@ func (h *testHostAPI) CacheDelete(ctx context.Context, key string) error {
 	return nil
 }
 
+func (h *testHostAPI) FilePut(ctx context.Context, path string, content []byte) error {
+	return nil
+}
+
+func (h *testHostAPI) FileGet(ctx context.Context, path string) ([]byte, bool, error) {
+	return nil, false, nil
+}
+
+func (h *testHostAPI) FileDelete(ctx context.Context, path string) error {
+	return nil
+}
+
 func (h *testHostAPI) HTTPRequest(ctx context.Context, method, url string, headers map[string]string, body []byte) (int, []byte, error) {
 	return 200, []byte(`{"status": "ok"}`), nil
 }
@@ -169,6 +189,10 @@ func (h *testHostAPI) ConfigGet(ctx context.Context, key string) (string, error)
 	return h.configStore[key], nil
 }
 
+func (h *testHostAPI) FeatureEnabled(ctx context.Context, flag string, userID int) (bool, error) {
+	return false, nil
+}
+
 func (h *testHostAPI) Translate(ctx context.Context, key string, args ...any) string {
 	return key
 }
@@ -0,0 +1,252 @@
+package plugin
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+type pluginTxKeyType struct{}
+
+// PluginTxKey is the context key carrying the transaction ID that DBQuery and
+// DBExec should run in. Runtimes set it from the "tx" field of db_query and
+// db_exec host calls.
+var PluginTxKey = pluginTxKeyType{}
+
+// WithTxID returns a context whose database calls run inside the given transaction.
+func WithTxID(ctx context.Context, txID string) context.Context {
+	if txID == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, PluginTxKey, txID)
+}
+
+// TxIDFromContext returns the transaction ID set by WithTxID, if any.
+func TxIDFromContext(ctx context.Context) string {
+	txID, _ := ctx.Value(PluginTxKey).(string)
+	return txID
+}
+
+// pluginTx is an open transaction owned by a plugin.
+type pluginTx struct {
+	mu         sync.Mutex
+	tx         *sql.Tx
+	plugin     string
+	dbName     string
+	statements int
+	maxStmts   int
+	timer      *time.Timer
+	cancel     context.CancelFunc
+}
+
+// sqlRunner is satisfied by both *sql.DB and *sql.Tx.
+type sqlRunner interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// ResourcePolicyFor returns the resource policy applied to a plugin.
+func (h *ProdHostAPI) ResourcePolicyFor(pluginName string) ResourcePolicy {
+	h.txMu.Lock()
+	defer h.txMu.Unlock()
+	if p, ok := h.policies[pluginName]; ok {
+		return p.withDefaults()
+	}
+	return h.defaultPolicy.withDefaults()
+}
+
+// DBBegin starts a transaction for the calling plugin and returns its ID.
+// The transaction is rolled back automatically once it has been open longer
+// than the plugin's ResourcePolicy.MaxTxDuration.
+func (h *ProdHostAPI) DBBegin(ctx context.Context, dbName string) (string, error) {
+	db, err := h.getDB(dbName)
+	if err != nil {
+		return "", err
+	}
+	if dbName == "" {
+		dbName = h.defaultDB
+	}
+
+	caller := callerPlugin(ctx)
+	policy := h.ResourcePolicyFor(caller)
+
+	h.txMu.Lock()
+	open := h.openTxCountLocked(caller)
+	h.txMu.Unlock()
+	if open >= policy.MaxOpenTx {
+		return "", ErrTxTooManyOpen
+	}
+
+	// The transaction outlives the host call that opened it, so it is bound
+	// to its own deadline rather than the caller's context.
+	txCtx, cancel := context.WithTimeout(context.Background(), policy.MaxTxDuration)
+	tx, err := db.BeginTx(txCtx, nil)
+	if err != nil {
+		cancel()
+		return "", fmt.Errorf("begin transaction: %w", err)
+	}
+
+	id, err := newTxID()
+	if err != nil {
+		_ = tx.Rollback()
+		cancel()
+		return "", err
+	}
+
+	ptx := &pluginTx{
+		tx:       tx,
+		plugin:   caller,
+		dbName:   dbName,
+		maxStmts: policy.MaxTxStatements,
+		cancel:   cancel,
+	}
+	ptx.timer = time.AfterFunc(policy.MaxTxDuration, func() {
+		if h.finishTx(id, false) == nil {
+			h.logger.Warn("plugin transaction rolled back after exceeding max duration",
+				"plugin", caller, "max_duration", policy.MaxTxDuration)
+		}
+	})
+
+	h.txMu.Lock()
+	// Re-check: another call may have opened a transaction meanwhile.
+	if h.openTxCountLocked(caller) >= policy.MaxOpenTx {
+		h.txMu.Unlock()
+		ptx.timer.Stop()
+		_ = tx.Rollback()
+		cancel()
+		return "", ErrTxTooManyOpen
+	}
+	if h.txs == nil {
+		h.txs = make(map[string]*pluginTx)
+	}
+	h.txs[id] = ptx
+	h.txMu.Unlock()
+	return id, nil
+}
+
+// openTxCountLocked counts a plugin's open transactions. Callers hold txMu.
+func (h *ProdHostAPI) openTxCountLocked(pluginName string) int {
+	n := 0
+	for _, t := range h.txs {
+		if t.plugin == pluginName {
+			n++
+		}
+	}
+	return n
+}
+
+// DBCommit commits a transaction opened by DBBegin.
+func (h *ProdHostAPI) DBCommit(ctx context.Context, txID string) error {
+	if _, err := h.lookupTx(ctx, txID); err != nil {
+		return err
+	}
+	return h.finishTx(txID, true)
+}
+
+// DBRollback rolls back a transaction opened by DBBegin.
+func (h *ProdHostAPI) DBRollback(ctx context.Context, txID string) error {
+	if _, err := h.lookupTx(ctx, txID); err != nil {
+		return err
+	}
+	return h.finishTx(txID, false)
+}
+
+// RollbackPluginTransactions rolls back every transaction still held by a
+// plugin, e.g. when it is unloaded.
+func (h *ProdHostAPI) RollbackPluginTransactions(pluginName string) {
+	h.txMu.Lock()
+	var ids []string
+	for id, t := range h.txs {
+		if t.plugin == pluginName {
+			ids = append(ids, id)
+		}
+	}
+	h.txMu.Unlock()
+	for _, id := range ids {
+		_ = h.finishTx(id, false)
+	}
+}
+
+// runnerFor returns the transaction named in ctx, or the database otherwise.
+// Statements inside a transaction count against its statement limit.
+func (h *ProdHostAPI) runnerFor(ctx context.Context, dbName string) (sqlRunner, error) {
+	txID := TxIDFromContext(ctx)
+	if txID == "" {
+		return h.getDB(dbName)
+	}
+
+	ptx, err := h.lookupTx(ctx, txID)
+	if err != nil {
+		return nil, err
+	}
+	if dbName != "" && dbName != ptx.dbName {
+		return nil, ErrTxDatabase
+	}
+
+	ptx.mu.Lock()
+	ptx.statements++
+	exceeded := ptx.statements > ptx.maxStmts
+	ptx.mu.Unlock()
+	if exceeded {
+		_ = h.finishTx(txID, false)
+		return nil, ErrTxStatementLimit
+	}
+	return ptx.tx, nil
+}
+
+// lookupTx returns an open transaction owned by the calling plugin.
+// Transactions of other plugins are reported as not found.
+func (h *ProdHostAPI) lookupTx(ctx context.Context, txID string) (*pluginTx, error) {
+	h.txMu.Lock()
+	defer h.txMu.Unlock()
+	ptx, ok := h.txs[txID]
+	if !ok || ptx.plugin != callerPlugin(ctx) {
+		return nil, ErrTxNotFound
+	}
+	return ptx, nil
+}
+
+// finishTx removes a transaction and commits or rolls it back.
+func (h *ProdHostAPI) finishTx(txID string, commit bool) error {
+	h.txMu.Lock()
+	ptx, ok := h.txs[txID]
+	if ok {
+		delete(h.txs, txID)
+	}
+	h.txMu.Unlock()
+	if !ok {
+		return ErrTxNotFound
+	}
+
+	ptx.timer.Stop()
+	defer ptx.cancel()
+
+	if commit {
+		if err := ptx.tx.Commit(); err != nil {
+			return fmt.Errorf("commit transaction: %w", err)
+		}
+		return nil
+	}
+	if err := ptx.tx.Rollback(); err != nil && !errors.Is(err, sql.ErrTxDone) {
+		return fmt.Errorf("rollback transaction: %w", err)
+	}
+	return nil
+}
+
+func callerPlugin(ctx context.Context) string {
+	caller, _ := ctx.Value(PluginCallerKey).(string)
+	return caller
+}
+
+func newTxID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate transaction id: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
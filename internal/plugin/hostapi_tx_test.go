@@ -0,0 +1,185 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/goatkit/goatflow/internal/testutil"
+)
+
+// newTxTestHost returns a host backed by the migrated schema plus an items
+// table standing in for a plugin's own table.
+func newTxTestHost(t *testing.T, opts ...ProdHostAPIOption) *ProdHostAPI {
+	t.Helper()
+	db := testutil.MigratedDB(t)
+	if _, err := db.Exec("CREATE TABLE items (id INTEGER PRIMARY KEY, name TEXT)"); err != nil {
+		t.Fatalf("failed to create table: %v", err)
+	}
+	return NewProdHostAPI(append([]ProdHostAPIOption{WithDB("default", db)}, opts...)...)
+}
+
+func countItems(t *testing.T, h *ProdHostAPI) int64 {
+	t.Helper()
+	rows, err := h.DBQuery(context.Background(), "SELECT COUNT(*) AS n FROM items")
+	if err != nil {
+		t.Fatalf("count query failed: %v", err)
+	}
+	return rows[0]["n"].(int64)
+}
+
+func TestProdHostAPI_TransactionCommit(t *testing.T) {
+	h := newTxTestHost(t)
+	ctx := context.WithValue(context.Background(), PluginCallerKey, "stats")
+
+	txID, err := h.DBBegin(ctx, "")
+	if err != nil {
+		t.Fatalf("DBBegin error: %v", err)
+	}
+	txCtx := WithTxID(ctx, txID)
+	for _, name := range []string{"a", "b"} {
+		if _, err := h.DBExec(txCtx, "INSERT INTO items (name) VALUES (?)", name); err != nil {
+			t.Fatalf("DBExec in tx error: %v", err)
+		}
+	}
+	rows, err := h.DBQuery(txCtx, "SELECT name FROM items ORDER BY id")
+	if err != nil || len(rows) != 2 {
+		t.Fatalf("expected 2 rows inside tx, got %d (err %v)", len(rows), err)
+	}
+	if err := h.DBCommit(ctx, txID); err != nil {
+		t.Fatalf("DBCommit error: %v", err)
+	}
+
+	if n := countItems(t, h); n != 2 {
+		t.Errorf("expected 2 committed rows, got %d", n)
+	}
+	if err := h.DBCommit(ctx, txID); !errors.Is(err, ErrTxNotFound) {
+		t.Errorf("expected ErrTxNotFound on second commit, got %v", err)
+	}
+}
+
+func TestProdHostAPI_TransactionRollback(t *testing.T) {
+	h := newTxTestHost(t)
+	ctx := context.WithValue(context.Background(), PluginCallerKey, "stats")
+
+	txID, err := h.DBBegin(ctx, "default")
+	if err != nil {
+		t.Fatalf("DBBegin error: %v", err)
+	}
+	if _, err := h.DBExec(WithTxID(ctx, txID), "INSERT INTO items (name) VALUES ('a')"); err != nil {
+		t.Fatalf("DBExec in tx error: %v", err)
+	}
+	if err := h.DBRollback(ctx, txID); err != nil {
+		t.Fatalf("DBRollback error: %v", err)
+	}
+
+	if n := countItems(t, h); n != 0 {
+		t.Errorf("expected no rows after rollback, got %d", n)
+	}
+}
+
+func TestProdHostAPI_TransactionStatementLimit(t *testing.T) {
+	h := newTxTestHost(t, WithResourcePolicy(ResourcePolicy{MaxTxStatements: 2}))
+	ctx := context.WithValue(context.Background(), PluginCallerKey, "stats")
+
+	txID, err := h.DBBegin(ctx, "")
+	if err != nil {
+		t.Fatalf("DBBegin error: %v", err)
+	}
+	txCtx := WithTxID(ctx, txID)
+	for i := 0; i < 2; i++ {
+		if _, err := h.DBExec(txCtx, "INSERT INTO items (name) VALUES ('x')"); err != nil {
+			t.Fatalf("statement %d error: %v", i+1, err)
+		}
+	}
+	if _, err := h.DBExec(txCtx, "INSERT INTO items (name) VALUES ('x')"); !errors.Is(err, ErrTxStatementLimit) {
+		t.Fatalf("expected ErrTxStatementLimit, got %v", err)
+	}
+
+	if err := h.DBCommit(ctx, txID); !errors.Is(err, ErrTxNotFound) {
+		t.Errorf("expected rolled back tx to be gone, got %v", err)
+	}
+	if n := countItems(t, h); n != 0 {
+		t.Errorf("expected partial writes to be rolled back, got %d rows", n)
+	}
+}
+
+func TestProdHostAPI_TransactionMaxDuration(t *testing.T) {
+	h := newTxTestHost(t, WithResourcePolicy(ResourcePolicy{MaxTxDuration: 50 * time.Millisecond}))
+	ctx := context.WithValue(context.Background(), PluginCallerKey, "stats")
+
+	txID, err := h.DBBegin(ctx, "")
+	if err != nil {
+		t.Fatalf("DBBegin error: %v", err)
+	}
+	if _, err := h.DBExec(WithTxID(ctx, txID), "INSERT INTO items (name) VALUES ('a')"); err != nil {
+		t.Fatalf("DBExec in tx error: %v", err)
+	}
+
+	time.Sleep(200 * time.Millisecond)
+
+	if err := h.DBCommit(ctx, txID); !errors.Is(err, ErrTxNotFound) {
+		t.Errorf("expected expired tx to be gone, got %v", err)
+	}
+	if n := countItems(t, h); n != 0 {
+		t.Errorf("expected expired tx to be rolled back, got %d rows", n)
+	}
+}
+
+func TestProdHostAPI_TransactionOwnership(t *testing.T) {
+	h := newTxTestHost(t)
+	owner := context.WithValue(context.Background(), PluginCallerKey, "stats")
+	other := context.WithValue(context.Background(), PluginCallerKey, "intruder")
+
+	txID, err := h.DBBegin(owner, "")
+	if err != nil {
+		t.Fatalf("DBBegin error: %v", err)
+	}
+	defer h.DBRollback(owner, txID)
+
+	if err := h.DBCommit(other, txID); !errors.Is(err, ErrTxNotFound) {
+		t.Errorf("expected other plugin commit to fail with ErrTxNotFound, got %v", err)
+	}
+	if _, err := h.DBExec(WithTxID(other, txID), "DELETE FROM items"); !errors.Is(err, ErrTxNotFound) {
+		t.Errorf("expected other plugin exec to fail with ErrTxNotFound, got %v", err)
+	}
+}
+
+func TestProdHostAPI_TransactionOpenLimit(t *testing.T) {
+	h := newTxTestHost(t, WithPluginResourcePolicy("stats", ResourcePolicy{MaxOpenTx: 1}))
+	ctx := context.WithValue(context.Background(), PluginCallerKey, "stats")
+
+	txID, err := h.DBBegin(ctx, "")
+	if err != nil {
+		t.Fatalf("DBBegin error: %v", err)
+	}
+	if _, err := h.DBBegin(ctx, ""); !errors.Is(err, ErrTxTooManyOpen) {
+		t.Errorf("expected ErrTxTooManyOpen, got %v", err)
+	}
+
+	h.RollbackPluginTransactions("stats")
+	if err := h.DBRollback(ctx, txID); !errors.Is(err, ErrTxNotFound) {
+		t.Errorf("expected tx to be rolled back on cleanup, got %v", err)
+	}
+}
+
+func TestResourcePolicyDefaults(t *testing.T) {
+	h := NewProdHostAPI(WithPluginResourcePolicy("stats", ResourcePolicy{MaxTxStatements: 5}))
+
+	p := h.ResourcePolicyFor("stats")
+	if p.MaxTxStatements != 5 {
+		t.Errorf("expected plugin override of 5 statements, got %d", p.MaxTxStatements)
+	}
+	if p.MaxTxDuration != DefaultResourcePolicy().MaxTxDuration {
+		t.Errorf("expected zero duration to fall back to default, got %v", p.MaxTxDuration)
+	}
+	if got := h.ResourcePolicyFor("other"); got != DefaultResourcePolicy() {
+		t.Errorf("expected default policy, got %+v", got)
+	}
+
+	h = NewProdHostAPI(WithPluginResourcePolicy("stats", ResourcePolicy{WidgetIsolation: "shadow-dom"}))
+	if got := h.ResourcePolicyFor("stats").WidgetIsolation; got != WidgetIsolationInline {
+		t.Errorf("expected unknown widget isolation to fall back to inline, got %q", got)
+	}
+}
@@ -14,14 +14,15 @@ import (
 	"github.com/fsnotify/fsnotify"
 
 	"github.com/goatkit/goatflow/internal/plugin"
+	"github.com/goatkit/goatflow/internal/plugin/sidecar"
 	"github.com/goatkit/goatflow/internal/plugin/wasm"
 )
 
 // DiscoveredPlugin holds info about a plugin found but not yet loaded.
 type DiscoveredPlugin struct {
 	Name     string // Derived from filename
-	Path     string // Full path to .wasm file
-	Type     string // "wasm" or "grpc"
+	Path     string // Full path to .wasm file or plugin.yaml
+	Type     string // "wasm", "exec" or "grpc"
 	Loaded   bool   // Whether it's been loaded
 	LoadedAt time.Time
 }
@@ -36,6 +37,7 @@ type Loader struct {
 	mu         sync.RWMutex
 	discovered map[string]*DiscoveredPlugin // name -> discovery info
 	lazy       bool                         // If true, don't load on discover
+	wasmOpts   []wasm.LoadOption            // Applied to every WASM plugin
 
 	// Hot reload
 	watcher     *fsnotify.Watcher
@@ -55,6 +57,14 @@ func WithLazyLoading() LoaderOption {
 	}
 }
 
+// WithWASMOptions sets the load options of WASM plugins, e.g. their
+// instance pool.
+func WithWASMOptions(opts ...wasm.LoadOption) LoaderOption {
+	return func(l *Loader) {
+		l.wasmOpts = opts
+	}
+}
+
 // NewLoader creates a plugin loader for the given directory.
 func NewLoader(pluginDir string, manager *plugin.Manager, logger *slog.Logger, opts ...LoaderOption) *Loader {
 	if logger == nil {
@@ -91,28 +101,50 @@ func (l *Loader) DiscoverAll() (int, error) {
 			return err
 		}
 		if d.IsDir() {
-			return nil
+			return l.skipDir(path, d)
 		}
 
-		ext := strings.ToLower(filepath.Ext(path))
-		if ext == ".wasm" {
-			name := strings.TrimSuffix(filepath.Base(path), ".wasm")
-			l.mu.Lock()
-			l.discovered[name] = &DiscoveredPlugin{
-				Name: name,
-				Path: path,
-				Type: "wasm",
+		var name, typ string
+		switch {
+		case strings.ToLower(filepath.Ext(path)) == ".wasm":
+			name, typ = strings.TrimSuffix(filepath.Base(path), ".wasm"), "wasm"
+		case d.Name() == sidecar.ManifestFile:
+			m, err := sidecar.LoadManifest(path)
+			if err != nil {
+				l.logger.Warn("skipping invalid plugin manifest", "path", path, "error", err)
+				return nil
 			}
-			l.mu.Unlock()
-			discovered++
-			l.logger.Debug("discovered plugin", "name", name, "path", path)
+			name, typ = m.Name, "exec"
+		default:
+			return nil
+		}
+		l.mu.Lock()
+		l.discovered[name] = &DiscoveredPlugin{
+			Name: name,
+			Path: path,
+			Type: typ,
 		}
+		l.mu.Unlock()
+		discovered++
+		l.logger.Debug("discovered plugin", "name", name, "path", path)
 		return nil
 	})
 
 	return discovered, err
 }
 
+// skipDir keeps the walk out of dependency and hidden directories that
+// sidecar plugins (node_modules, .venv) bring along.
+func (l *Loader) skipDir(path string, d fs.DirEntry) error {
+	if path == l.pluginDir {
+		return nil
+	}
+	if name := d.Name(); name == "node_modules" || name == "__pycache__" || strings.HasPrefix(name, ".") {
+		return filepath.SkipDir
+	}
+	return nil
+}
+
 // Discovered returns the names of discovered (but possibly not loaded) plugins.
 // Implements plugin.LazyLoader interface.
 func (l *Loader) Discovered() []string {
@@ -160,7 +192,7 @@ func (l *Loader) EnsureLoaded(ctx context.Context, name string) error {
 	}
 
 	l.logger.Info("lazy loading plugin", "name", name)
-	if err := l.loadWASMPlugin(ctx, d.Path); err != nil {
+	if err := l.loadDiscovered(ctx, d); err != nil {
 		return err
 	}
 
@@ -203,7 +235,7 @@ func (l *Loader) LoadAll(ctx context.Context) (int, []error) {
 
 		// Skip directories (but descend into them)
 		if d.IsDir() {
-			return nil
+			return l.skipDir(path, d)
 		}
 
 		// Load based on file extension
@@ -227,6 +259,25 @@ func (l *Loader) LoadAll(ctx context.Context) (int, []error) {
 			} else {
 				loaded++
 			}
+		case ".yaml":
+			if d.Name() != sidecar.ManifestFile {
+				return nil
+			}
+			name, err := l.loadExecPlugin(ctx, path)
+			if err != nil {
+				errors = append(errors, fmt.Errorf("load %s: %w", path, err))
+				return nil
+			}
+			l.mu.Lock()
+			l.discovered[name] = &DiscoveredPlugin{
+				Name:     name,
+				Path:     path,
+				Type:     "exec",
+				Loaded:   true,
+				LoadedAt: time.Now(),
+			}
+			l.mu.Unlock()
+			loaded++
 		case ".zip":
 			// TODO: Extract and load plugin package
 			l.logger.Debug("skipping zip package (not yet implemented)", "path", path)
@@ -247,7 +298,7 @@ func (l *Loader) loadWASMPlugin(ctx context.Context, path string) error {
 	l.logger.Info("loading WASM plugin", "path", path)
 
 	// Load the WASM module
-	plugin, err := wasm.LoadFromFile(ctx, path)
+	plugin, err := wasm.LoadFromFile(ctx, path, l.wasmOpts...)
 	if err != nil {
 		return fmt.Errorf("load wasm: %w", err)
 	}
@@ -272,6 +323,42 @@ func (l *Loader) loadWASMPlugin(ctx context.Context, path string) error {
 	return nil
 }
 
+// loadExecPlugin starts the sidecar plugin declared by a plugin.yaml and
+// returns its registered name.
+func (l *Loader) loadExecPlugin(ctx context.Context, path string) (string, error) {
+	l.logger.Info("starting sidecar plugin", "path", path)
+
+	p, err := sidecar.Load(ctx, path)
+	if err != nil {
+		return "", fmt.Errorf("start sidecar: %w", err)
+	}
+
+	manifest := p.GKRegister()
+	l.logger.Info("loaded plugin",
+		"name", manifest.Name,
+		"version", manifest.Version,
+		"runtime", sidecar.RuntimeExec,
+		"routes", len(manifest.Routes),
+		"widgets", len(manifest.Widgets),
+		"jobs", len(manifest.Jobs),
+	)
+
+	if err := l.manager.Register(ctx, p); err != nil {
+		p.Shutdown(ctx)
+		return "", fmt.Errorf("register: %w", err)
+	}
+	return manifest.Name, nil
+}
+
+// loadDiscovered loads a discovered plugin with the runtime its type names.
+func (l *Loader) loadDiscovered(ctx context.Context, d *DiscoveredPlugin) error {
+	if d.Type == "exec" {
+		_, err := l.loadExecPlugin(ctx, d.Path)
+		return err
+	}
+	return l.loadWASMPlugin(ctx, d.Path)
+}
+
 // LoadWASM loads a single WASM plugin by name (without .wasm extension).
 func (l *Loader) LoadWASM(ctx context.Context, name string) error {
 	path := filepath.Join(l.pluginDir, name+".wasm")
@@ -290,10 +377,15 @@ func (l *Loader) Unload(ctx context.Context, name string) error {
 
 // Reload unloads and reloads a plugin by name.
 func (l *Loader) Reload(ctx context.Context, name string) error {
-	// Find the plugin file
-	path := filepath.Join(l.pluginDir, name+".wasm")
-	if _, err := os.Stat(path); os.IsNotExist(err) {
-		return fmt.Errorf("plugin file not found: %s", path)
+	// Find the plugin file; sidecar plugins are known by their manifest
+	d := &DiscoveredPlugin{Name: name, Path: filepath.Join(l.pluginDir, name+".wasm"), Type: "wasm"}
+	l.mu.RLock()
+	if known, ok := l.discovered[name]; ok && known.Type == "exec" {
+		d = known
+	}
+	l.mu.RUnlock()
+	if _, err := os.Stat(d.Path); os.IsNotExist(err) {
+		return fmt.Errorf("plugin file not found: %s", d.Path)
 	}
 
 	// Unload if currently loaded
@@ -304,11 +396,11 @@ func (l *Loader) Reload(ctx context.Context, name string) error {
 	}
 
 	// Load fresh
-	return l.loadWASMPlugin(ctx, path)
+	return l.loadDiscovered(ctx, d)
 }
 
 // WatchDir sets up a file watcher for hot reload.
-// When WASM files are created, modified, or removed, the corresponding plugin is reloaded.
+// When WASM files or sidecar manifests are created, modified, or removed, the corresponding plugin is reloaded.
 func (l *Loader) WatchDir(ctx context.Context) error {
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
@@ -384,8 +476,8 @@ func (l *Loader) watchLoop() {
 
 // handleFSEvent processes a single file system event with debouncing.
 func (l *Loader) handleFSEvent(event fsnotify.Event) {
-	// Only care about WASM files
-	if !strings.HasSuffix(strings.ToLower(event.Name), ".wasm") {
+	// Only care about WASM files and sidecar manifests
+	if !strings.HasSuffix(strings.ToLower(event.Name), ".wasm") && filepath.Base(event.Name) != sidecar.ManifestFile {
 		return
 	}
 
@@ -400,15 +492,50 @@ func (l *Loader) handleFSEvent(event fsnotify.Event) {
 	l.watchMu.Unlock()
 }
 
+// execPluginName returns the name of the sidecar plugin declared at path,
+// preferring the one recorded when it was loaded so removals resolve too.
+func (l *Loader) execPluginName(path string) string {
+	l.mu.RLock()
+	for _, d := range l.discovered {
+		if d.Path == path {
+			l.mu.RUnlock()
+			return d.Name
+		}
+	}
+	l.mu.RUnlock()
+	if m, err := sidecar.LoadManifest(path); err == nil {
+		return m.Name
+	}
+	return filepath.Base(filepath.Dir(path))
+}
+
+// loadPath loads a new plugin file found by the watcher.
+func (l *Loader) loadPath(ctx context.Context, path string) error {
+	if filepath.Base(path) != sidecar.ManifestFile {
+		return l.loadWASMPlugin(ctx, path)
+	}
+	name, err := l.loadExecPlugin(ctx, path)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	l.discovered[name] = &DiscoveredPlugin{Name: name, Path: path, Type: "exec", Loaded: true, LoadedAt: time.Now()}
+	l.mu.Unlock()
+	return nil
+}
+
 // processFileChange handles the actual plugin reload after debounce.
 func (l *Loader) processFileChange(event fsnotify.Event) {
 	path := event.Name
 	name := strings.TrimSuffix(filepath.Base(path), ".wasm")
+	if filepath.Base(path) == sidecar.ManifestFile {
+		name = l.execPluginName(path)
+	}
 
 	switch {
 	case event.Op&fsnotify.Create == fsnotify.Create:
 		l.logger.Info("🔌 new plugin detected", "name", name)
-		if err := l.loadWASMPlugin(l.watchCtx, path); err != nil {
+		if err := l.loadPath(l.watchCtx, path); err != nil {
 			l.logger.Error("failed to load new plugin", "name", name, "error", err)
 		} else {
 			l.logger.Info("✅ plugin loaded", "name", name)
@@ -21,13 +21,23 @@ func (m *mockHostAPI) DBQuery(ctx context.Context, query string, args ...any) ([
 func (m *mockHostAPI) DBExec(ctx context.Context, query string, args ...any) (int64, error) {
 	return 0, nil
 }
+func (m *mockHostAPI) DBBegin(ctx context.Context, dbName string) (string, error) {
+	return "tx-1", nil
+}
+func (m *mockHostAPI) DBCommit(ctx context.Context, txID string) error   { return nil }
+func (m *mockHostAPI) DBRollback(ctx context.Context, txID string) error { return nil }
 func (m *mockHostAPI) CacheGet(ctx context.Context, key string) ([]byte, bool, error) {
 	return nil, false, nil
 }
 func (m *mockHostAPI) CacheSet(ctx context.Context, key string, value []byte, ttlSeconds int) error {
 	return nil
 }
-func (m *mockHostAPI) CacheDelete(ctx context.Context, key string) error { return nil }
+func (m *mockHostAPI) CacheDelete(ctx context.Context, key string) error              { return nil }
+func (m *mockHostAPI) FilePut(ctx context.Context, path string, content []byte) error { return nil }
+func (m *mockHostAPI) FileGet(ctx context.Context, path string) ([]byte, bool, error) {
+	return nil, false, nil
+}
+func (m *mockHostAPI) FileDelete(ctx context.Context, path string) error { return nil }
 func (m *mockHostAPI) HTTPRequest(ctx context.Context, method, url string, headers map[string]string, body []byte) (int, []byte, error) {
 	return 200, nil, nil
 }
@@ -38,6 +48,9 @@ func (m *mockHostAPI) Log(ctx context.Context, level, message string, fields map
 func (m *mockHostAPI) ConfigGet(ctx context.Context, key string) (string, error) {
 	return "", nil
 }
+func (m *mockHostAPI) FeatureEnabled(ctx context.Context, flag string, userID int) (bool, error) {
+	return false, nil
+}
 func (m *mockHostAPI) Translate(ctx context.Context, key string, args ...any) string { return "" }
 func (m *mockHostAPI) CallPlugin(ctx context.Context, pluginName, function string, args json.RawMessage) (json.RawMessage, error) {
 	return nil, nil
@@ -247,6 +260,29 @@ func TestLoaderDiscoverAll_Variants(t *testing.T) {
 			t.Errorf("expected 3 plugins (case-insensitive), got %d", count)
 		}
 	})
+
+	t.Run("sidecar manifests", func(t *testing.T) {
+		tmpDir := t.TempDir()
+		os.MkdirAll(filepath.Join(tmpDir, "greeter", "node_modules", "dep"), 0755)
+		os.MkdirAll(filepath.Join(tmpDir, "broken"), 0755)
+		os.WriteFile(filepath.Join(tmpDir, "greeter", "plugin.yaml"), []byte("name: greeter\nruntime: exec\ncommand: [node, index.js]\n"), 0644)
+		os.WriteFile(filepath.Join(tmpDir, "greeter", "node_modules", "dep", "plugin.yaml"), []byte("runtime: exec\ncommand: [node]\n"), 0644)
+		os.WriteFile(filepath.Join(tmpDir, "broken", "plugin.yaml"), []byte("runtime: python\n"), 0644)
+
+		l := loader.NewLoader(tmpDir, mgr, nil)
+		count, err := l.DiscoverAll()
+		if err != nil {
+			t.Fatalf("DiscoverAll failed: %v", err)
+		}
+		// node_modules is skipped and the invalid manifest ignored
+		if count != 1 {
+			t.Fatalf("expected 1 sidecar plugin, got %d", count)
+		}
+		d := l.DiscoveredPlugins()[0]
+		if d.Name != "greeter" || d.Type != "exec" {
+			t.Errorf("unexpected discovery: %+v", d)
+		}
+	})
 }
 
 func TestLoaderEnsureLoaded(t *testing.T) {
@@ -3,24 +3,22 @@ package plugin
 import (
 	"sync"
 	"time"
+
+	"github.com/goatkit/goatflow/internal/models"
 )
 
 // LogEntry represents a single plugin log entry.
-type LogEntry struct {
-	Timestamp  time.Time      `json:"timestamp"`
-	Plugin     string         `json:"plugin"`
-	Level      string         `json:"level"` // debug, info, warn, error
-	Message    string         `json:"message"`
-	Fields     map[string]any `json:"fields,omitempty"`
-}
+type LogEntry = models.PluginLogEntry
 
-// LogBuffer is a ring buffer for plugin logs.
+// LogBuffer is a ring buffer for plugin logs. Subscribers receive entries
+// as they are added, for persisting and live tailing.
 type LogBuffer struct {
 	mu      sync.RWMutex
 	entries []LogEntry
 	maxSize int
 	head    int
 	count   int
+	subs    map[chan LogEntry]struct{}
 }
 
 // NewLogBuffer creates a new log buffer with the given max size.
@@ -44,6 +42,35 @@ func (b *LogBuffer) Add(entry LogEntry) {
 	if b.count < b.maxSize {
 		b.count++
 	}
+	for ch := range b.subs {
+		select {
+		case ch <- entry:
+		default: // Slow subscriber, drop rather than block the plugin
+		}
+	}
+}
+
+// Subscribe returns a channel receiving the entries added from now on and
+// a function ending the subscription, which closes the channel. Entries a
+// subscriber has no room for in its buffer of size are dropped.
+func (b *LogBuffer) Subscribe(size int) (<-chan LogEntry, func()) {
+	ch := make(chan LogEntry, size)
+	b.mu.Lock()
+	if b.subs == nil {
+		b.subs = make(map[chan LogEntry]struct{})
+	}
+	b.subs[ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subs, ch)
+			b.mu.Unlock()
+			close(ch)
+		})
+	}
 }
 
 // Log adds a log entry with the given parameters.
@@ -165,3 +165,29 @@ func TestNewLogBufferInvalidSize(t *testing.T) {
 		}
 	})
 }
+
+func TestLogBufferSubscribe(t *testing.T) {
+	buf := NewLogBuffer(10)
+	entries, cancel := buf.Subscribe(1)
+
+	buf.Log("stats", "info", "first", nil)
+	buf.Log("stats", "info", "dropped", nil)
+	if got := <-entries; got.Message != "first" {
+		t.Errorf("expected first entry, got %q", got.Message)
+	}
+	select {
+	case got := <-entries:
+		t.Errorf("entry beyond the subscriber's buffer should be dropped, got %q", got.Message)
+	default:
+	}
+	if buf.Count() != 2 {
+		t.Errorf("buffer should keep dropped entries, got %d", buf.Count())
+	}
+
+	cancel()
+	cancel()
+	if _, ok := <-entries; ok {
+		t.Error("channel should be closed after cancel")
+	}
+	buf.Log("stats", "info", "after", nil) // must not panic on the closed channel
+}
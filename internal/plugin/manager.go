@@ -2,11 +2,18 @@ package plugin
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/url"
+	"runtime/debug"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/goatkit/goatflow/internal/apierrors"
 	"github.com/goatkit/goatflow/internal/i18n"
+	"github.com/goatkit/goatflow/internal/telemetry"
+	"github.com/goatkit/goatflow/internal/tracing"
 )
 
 // LazyLoader is the interface for lazy-loading plugins on demand.
@@ -17,11 +24,28 @@ type LazyLoader interface {
 
 // Manager handles plugin lifecycle: loading, registration, and invocation.
 type Manager struct {
-	mu         sync.RWMutex
-	plugins    map[string]*registeredPlugin
-	host       HostAPI
-	lazyLoader LazyLoader // Optional: for lazy loading support
-}
+	mu          sync.RWMutex
+	plugins     map[string]*registeredPlugin
+	host        HostAPI
+	lazyLoader  LazyLoader      // Optional: for lazy loading support
+	migrator    *SchemaMigrator // Optional: applies plugin schema migrations
+	listener    LifecycleListener
+	widgetCache *widgetCache // rendered widget HTML, see RenderWidget
+	crashes     *crashLog    // latest crash reports, see CrashReports
+	breakers    *breakers    // per-plugin circuit breakers, see SetBreakerPolicy
+}
+
+// Lifecycle events reported to a LifecycleListener.
+const (
+	LifecycleRegistered   = "registered"
+	LifecycleUnregistered = "unregistered"
+	LifecycleEnabled      = "enabled"
+	LifecycleDisabled     = "disabled"
+)
+
+// LifecycleListener is notified after a plugin changes state. It is called
+// with the manager locked and must not block or call back into the manager.
+type LifecycleListener func(pluginName, event string)
 
 type registeredPlugin struct {
 	plugin   Plugin
@@ -32,8 +56,11 @@ type registeredPlugin struct {
 // NewManager creates a plugin manager with the given host API.
 func NewManager(host HostAPI) *Manager {
 	return &Manager{
-		plugins: make(map[string]*registeredPlugin),
-		host:    host,
+		plugins:     make(map[string]*registeredPlugin),
+		host:        host,
+		widgetCache: newWidgetCache(),
+		crashes:     newCrashLog(),
+		breakers:    newBreakers(),
 	}
 }
 
@@ -110,6 +137,30 @@ func (m *Manager) SetLazyLoader(loader LazyLoader) {
 	m.lazyLoader = loader
 }
 
+// SetSchemaMigrator sets the migrator that applies manifest migrations when
+// plugins are registered. Without one, plugins declaring migrations fail to
+// register.
+func (m *Manager) SetSchemaMigrator(migrator *SchemaMigrator) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.migrator = migrator
+}
+
+// SetLifecycleListener sets the function notified when plugins are
+// registered, unregistered, enabled or disabled.
+func (m *Manager) SetLifecycleListener(fn LifecycleListener) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.listener = fn
+}
+
+// notify reports a lifecycle event. Callers hold m.mu.
+func (m *Manager) notify(name, event string) {
+	if m.listener != nil {
+		m.listener(name, event)
+	}
+}
+
 // Discovered returns the names of discovered but not necessarily loaded plugins.
 func (m *Manager) Discovered() []string {
 	if m.lazyLoader == nil {
@@ -129,6 +180,16 @@ func (m *Manager) Register(ctx context.Context, p Plugin) error {
 		return fmt.Errorf("plugin %q already registered", manifest.Name)
 	}
 
+	// Bring the plugin's own tables up to date before it can use them
+	if len(manifest.Migrations) > 0 {
+		if m.migrator == nil {
+			return fmt.Errorf("plugin %q declares migrations but no schema migrator is configured", manifest.Name)
+		}
+		if _, err := m.migrator.Migrate(ctx, manifest); err != nil {
+			return fmt.Errorf("plugin %q schema migration failed: %w", manifest.Name, err)
+		}
+	}
+
 	// Initialize the plugin with host API access
 	if err := p.Init(ctx, m.host); err != nil {
 		return fmt.Errorf("plugin %q init failed: %w", manifest.Name, err)
@@ -142,6 +203,7 @@ func (m *Manager) Register(ctx context.Context, p Plugin) error {
 		manifest: manifest,
 		enabled:  isEnabled,
 	}
+	m.widgetCache.invalidate(manifest.Name)
 
 	// Load plugin translations if provided
 	if manifest.I18n != nil && len(manifest.I18n.Translations) > 0 {
@@ -153,13 +215,26 @@ func (m *Manager) Register(ctx context.Context, p Plugin) error {
 		m.loadPluginErrorCodes(manifest.Name, manifest.ErrorCodes)
 	}
 
+	// Invalid admin pages are not offered; the rest of the plugin still loads
+	for _, page := range manifest.AdminPages {
+		if err := page.Validate(); err != nil {
+			fmt.Printf("Warning: plugin %q: %v\n", manifest.Name, err)
+			GetLogBuffer().Log(manifest.Name, "error", err.Error(), nil)
+		}
+	}
+
 	// Register template overrides if provided
 	if len(manifest.Templates) > 0 {
 		if registry := GetTemplateOverrides(); registry != nil {
-			registry.Register(manifest.Name, manifest.Templates)
+			// Rejected templates fall back to the host ones; the plugin still loads
+			if err := registry.Register(manifest.Name, manifest.Templates); err != nil {
+				fmt.Printf("Warning: plugin %q: %v\n", manifest.Name, err)
+				GetLogBuffer().Log(manifest.Name, "error", err.Error(), nil)
+			}
 		}
 	}
 
+	m.notify(manifest.Name, LifecycleRegistered)
 	return nil
 }
 
@@ -209,10 +284,59 @@ func (m *Manager) Unregister(ctx context.Context, name string) error {
 		return fmt.Errorf("plugin %q shutdown failed: %w", name, err)
 	}
 
+	// Don't leave transactions open for a plugin that is gone.
+	if txHost, ok := m.host.(interface{ RollbackPluginTransactions(string) }); ok {
+		txHost.RollbackPluginTransactions(name)
+	}
+
 	delete(m.plugins, name)
+	m.widgetCache.invalidate(name)
+	m.breakers.reset(name)
+	if registry := GetTemplateOverrides(); registry != nil {
+		registry.Unregister(name)
+	}
+	m.notify(name, LifecycleUnregistered)
 	return nil
 }
 
+// Uninstall unregisters a plugin and either drops its schema namespace or
+// retains it for a later reinstall.
+func (m *Manager) Uninstall(ctx context.Context, name string, dropData bool) error {
+	m.mu.RLock()
+	rp, exists := m.plugins[name]
+	migrator := m.migrator
+	m.mu.RUnlock()
+	if !exists {
+		return fmt.Errorf("plugin %q not found", name)
+	}
+
+	if err := m.Unregister(ctx, name); err != nil {
+		return err
+	}
+	if migrator == nil || len(rp.manifest.Migrations) == 0 {
+		return nil
+	}
+	if err := migrator.Uninstall(ctx, rp.manifest, dropData); err != nil {
+		return fmt.Errorf("plugin %q schema removal failed: %w", name, err)
+	}
+	return nil
+}
+
+// SchemaStatus returns the migration state of a registered plugin.
+func (m *Manager) SchemaStatus(ctx context.Context, name string) (*SchemaStatus, error) {
+	m.mu.RLock()
+	rp, exists := m.plugins[name]
+	migrator := m.migrator
+	m.mu.RUnlock()
+	if !exists {
+		return nil, fmt.Errorf("plugin %q not found", name)
+	}
+	if migrator == nil {
+		return &SchemaStatus{Plugin: name, Namespace: SchemaPrefix(name)}, nil
+	}
+	return migrator.Status(ctx, rp.manifest)
+}
+
 // Get returns a plugin by name.
 func (m *Manager) Get(name string) (Plugin, bool) {
 	m.mu.RLock()
@@ -253,9 +377,33 @@ func (e *PluginDisabledError) Error() string {
 	return fmt.Sprintf("plugin %q is disabled", e.PluginName)
 }
 
+// PluginTimeoutError is returned when a plugin call ran into its call
+// timeout or the caller's deadline.
+type PluginTimeoutError struct {
+	PluginName string
+	Function   string
+	Timeout    time.Duration // The limit the call ran into
+}
+
+func (e *PluginTimeoutError) Error() string {
+	return fmt.Sprintf("plugin %q timed out in %q after %s", e.PluginName, e.Function, e.Timeout.Round(time.Millisecond))
+}
+
+func (e *PluginTimeoutError) Unwrap() error { return context.DeadlineExceeded }
+
 // Call invokes a function on a specific plugin.
 // If lazy loading is enabled and the plugin isn't loaded yet, it will be loaded first.
-func (m *Manager) Call(ctx context.Context, pluginName, fn string, args []byte) ([]byte, error) {
+func (m *Manager) Call(ctx context.Context, pluginName, fn string, args []byte) (result []byte, err error) {
+	start := time.Now()
+	ctx, span := tracing.Start(ctx, "plugin "+pluginName+"."+fn,
+		tracing.String("plugin.name", pluginName),
+		tracing.String("plugin.function", fn),
+	)
+	defer func() {
+		span.Finish(err)
+		telemetry.RecordPluginCall(pluginName, fn, time.Since(start), err)
+	}()
+
 	m.mu.RLock()
 	rp, exists := m.plugins[pluginName]
 	lazyLoader := m.lazyLoader
@@ -279,12 +427,23 @@ func (m *Manager) Call(ctx context.Context, pluginName, fn string, args []byte)
 		return nil, &PluginDisabledError{PluginName: pluginName}
 	}
 
-	return rp.plugin.Call(ctx, fn, args)
+	return m.invoke(ctx, pluginName, rp.plugin, fn, args)
 }
 
 // CallFrom invokes a function on a plugin, with caller context for better errors.
 // If lazy loading is enabled and the plugin isn't loaded yet, it will be loaded first.
-func (m *Manager) CallFrom(ctx context.Context, callerPlugin, targetPlugin, fn string, args []byte) ([]byte, error) {
+func (m *Manager) CallFrom(ctx context.Context, callerPlugin, targetPlugin, fn string, args []byte) (result []byte, err error) {
+	start := time.Now()
+	ctx, span := tracing.Start(ctx, "plugin "+targetPlugin+"."+fn,
+		tracing.String("plugin.name", targetPlugin),
+		tracing.String("plugin.function", fn),
+		tracing.String("plugin.caller", callerPlugin),
+	)
+	defer func() {
+		span.Finish(err)
+		telemetry.RecordPluginCall(targetPlugin, fn, time.Since(start), err)
+	}()
+
 	m.mu.RLock()
 	rp, exists := m.plugins[targetPlugin]
 	lazyLoader := m.lazyLoader
@@ -319,7 +478,50 @@ func (m *Manager) CallFrom(ctx context.Context, callerPlugin, targetPlugin, fn s
 		}
 	}
 
-	return rp.plugin.Call(ctx, fn, args)
+	result, err = m.invoke(ctx, targetPlugin, rp.plugin, fn, args)
+	var unavailable *PluginUnavailableError
+	if errors.As(err, &unavailable) {
+		unavailable.CallerPlugin = callerPlugin
+	}
+	return result, err
+}
+
+// invoke calls fn on p within the plugin's call timeout unless its circuit
+// breaker is open. A panic of an in-process plugin becomes a CrashError,
+// and crashes are recorded.
+func (m *Manager) invoke(ctx context.Context, name string, p Plugin, fn string, args []byte) (result []byte, err error) {
+	if retry, ok := m.breakers.allow(name); !ok {
+		return nil, &PluginUnavailableError{PluginName: name, RetryAfter: retry}
+	}
+	timeout := m.callTimeout(name)
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < timeout {
+		timeout = time.Until(deadline)
+	}
+	callCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	defer func() {
+		if r := recover(); r != nil {
+			err = &CrashError{Runtime: CrashRuntimeGo, Reason: fmt.Sprint(r), Stack: string(debug.Stack())}
+		}
+		var crash *CrashError
+		if errors.As(err, &crash) {
+			crash.Plugin, crash.Function = name, fn
+			m.recordCrash(crash, len(args))
+		} else if err != nil && (errors.Is(err, context.DeadlineExceeded) || callCtx.Err() == context.DeadlineExceeded) {
+			err = &PluginTimeoutError{PluginName: name, Function: fn, Timeout: timeout}
+		}
+		m.breakers.record(name, callFailed(ctx, err))
+	}()
+	return p.Call(callCtx, fn, args)
+}
+
+// callTimeout returns the call timeout from the plugin's resource policy on
+// the host, or the default for hosts without policies.
+func (m *Manager) callTimeout(name string) time.Duration {
+	if policyHost, ok := m.host.(interface{ ResourcePolicyFor(string) ResourcePolicy }); ok {
+		return policyHost.ResourcePolicyFor(name).CallTimeout
+	}
+	return DefaultResourcePolicy().CallTimeout
 }
 
 // List returns all registered plugin manifests.
@@ -372,6 +574,8 @@ func (m *Manager) Enable(name string) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	rp.enabled = true
+	m.widgetCache.invalidate(name)
+	m.breakers.reset(name) // Re-enabling gives a broken plugin a fresh start
 
 	// Persist state to sysconfig
 	ctx := context.Background()
@@ -379,6 +583,7 @@ func (m *Manager) Enable(name string) error {
 		// Log but don't fail - in-memory state is still correct
 		fmt.Printf("Warning: failed to save plugin state: %v\n", err)
 	}
+	m.notify(name, LifecycleEnabled)
 	return nil
 }
 
@@ -392,6 +597,7 @@ func (m *Manager) Disable(name string) error {
 		return fmt.Errorf("plugin %q not found", name)
 	}
 	rp.enabled = false
+	m.widgetCache.invalidate(name)
 
 	// Persist state to sysconfig
 	ctx := context.Background()
@@ -399,6 +605,7 @@ func (m *Manager) Disable(name string) error {
 		// Log but don't fail - in-memory state is still correct
 		fmt.Printf("Warning: failed to save plugin state: %v\n", err)
 	}
+	m.notify(name, LifecycleDisabled)
 	return nil
 }
 
@@ -456,6 +663,54 @@ type PluginMenuItem struct {
 	MenuItemSpec
 }
 
+// AdminPages returns the valid admin pages of all enabled plugins, ordered by
+// plugin name and page order. Plugins whose widgets are iframe-isolated get
+// none, as admin pages run unsandboxed in the admin's session.
+func (m *Manager) AdminPages() []PluginAdminPage {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var pages []PluginAdminPage
+	for name, rp := range m.plugins {
+		if !rp.enabled || m.WidgetIsolation(name) == WidgetIsolationIframe {
+			continue
+		}
+		for _, p := range rp.manifest.AdminPages {
+			if p.Validate() == nil {
+				pages = append(pages, PluginAdminPage{PluginName: name, AdminPageSpec: p})
+			}
+		}
+	}
+	sort.SliceStable(pages, func(i, j int) bool {
+		if pages[i].PluginName != pages[j].PluginName {
+			return pages[i].PluginName < pages[j].PluginName
+		}
+		return pages[i].Order < pages[j].Order
+	})
+	return pages
+}
+
+// AdminPage returns an admin page of an enabled plugin.
+func (m *Manager) AdminPage(pluginName, id string) (PluginAdminPage, bool) {
+	for _, p := range m.AdminPages() {
+		if p.PluginName == pluginName && p.ID == id {
+			return p, true
+		}
+	}
+	return PluginAdminPage{}, false
+}
+
+// PluginAdminPage pairs an admin page spec with its plugin name.
+type PluginAdminPage struct {
+	PluginName string
+	AdminPageSpec
+}
+
+// URL returns the path the page is served at.
+func (p PluginAdminPage) URL() string {
+	return "/admin/plugins/" + url.PathEscape(p.PluginName) + "/pages/" + p.ID
+}
+
 // Widgets returns all widgets from all enabled plugins for a location.
 func (m *Manager) Widgets(location string) []PluginWidget {
 	m.mu.RLock()
@@ -471,6 +726,7 @@ func (m *Manager) Widgets(location string) []PluginWidget {
 				widgets = append(widgets, PluginWidget{
 					PluginName: name,
 					WidgetSpec: w,
+					Isolation:  m.WidgetIsolation(name),
 				})
 			}
 		}
@@ -482,6 +738,25 @@ func (m *Manager) Widgets(location string) []PluginWidget {
 type PluginWidget struct {
 	PluginName string
 	WidgetSpec
+	Isolation WidgetIsolation
+}
+
+// WidgetIsolation returns how a plugin's widgets must be rendered, as set by
+// its resource policy on the host. Hosts without policies render inline.
+func (m *Manager) WidgetIsolation(name string) WidgetIsolation {
+	if policyHost, ok := m.host.(interface{ ResourcePolicyFor(string) ResourcePolicy }); ok {
+		return policyHost.ResourcePolicyFor(name).WidgetIsolation
+	}
+	return WidgetIsolationInline
+}
+
+// PublicRoutes returns the public routes capability granted to a plugin by
+// its resource policy on the host, or nil when it has none.
+func (m *Manager) PublicRoutes(name string) *PublicRoutePolicy {
+	if policyHost, ok := m.host.(interface{ ResourcePolicyFor(string) ResourcePolicy }); ok {
+		return policyHost.ResourcePolicyFor(name).PublicRoutes
+	}
+	return nil
 }
 
 // AllWidgets returns widgets from all plugins (including lazy-loaded) for a location.
@@ -3,8 +3,10 @@ package plugin_test
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/goatkit/goatflow/internal/plugin"
 	"github.com/goatkit/goatflow/internal/plugin/example"
@@ -29,6 +31,14 @@ func (m *mockHostAPI) DBExec(ctx context.Context, query string, args ...any) (in
 	return 0, nil
 }
 
+func (m *mockHostAPI) DBBegin(ctx context.Context, dbName string) (string, error) {
+	return "tx-1", nil
+}
+
+func (m *mockHostAPI) DBCommit(ctx context.Context, txID string) error { return nil }
+
+func (m *mockHostAPI) DBRollback(ctx context.Context, txID string) error { return nil }
+
 func (m *mockHostAPI) CacheGet(ctx context.Context, key string) ([]byte, bool, error) {
 	return nil, false, nil
 }
@@ -41,6 +51,18 @@ func (m *mockHostAPI) CacheDelete(ctx context.Context, key string) error {
 	return nil
 }
 
+func (m *mockHostAPI) FilePut(ctx context.Context, path string, content []byte) error {
+	return nil
+}
+
+func (m *mockHostAPI) FileGet(ctx context.Context, path string) ([]byte, bool, error) {
+	return nil, false, nil
+}
+
+func (m *mockHostAPI) FileDelete(ctx context.Context, path string) error {
+	return nil
+}
+
 func (m *mockHostAPI) HTTPRequest(ctx context.Context, method, url string, headers map[string]string, body []byte) (int, []byte, error) {
 	return 200, nil, nil
 }
@@ -79,6 +101,10 @@ func (m *mockHostAPI) ConfigGet(ctx context.Context, key string) (string, error)
 	return "", nil
 }
 
+func (m *mockHostAPI) FeatureEnabled(ctx context.Context, flag string, userID int) (bool, error) {
+	return false, nil
+}
+
 func (m *mockHostAPI) Translate(ctx context.Context, key string, args ...any) string {
 	return ""
 }
@@ -340,6 +366,89 @@ func TestPluginManagerGetRoutes(t *testing.T) {
 	}
 }
 
+func TestRouteSpecAccess(t *testing.T) {
+	public := plugin.RouteSpec{Method: "GET", Path: "/api/plugins/open"}
+	if public.RequiresAccess() || public.ValidateAccess() != nil {
+		t.Error("route without groups or queues should be unrestricted and valid")
+	}
+
+	grouped := plugin.RouteSpec{Method: "GET", Path: "/api/plugins/grouped", Groups: []string{"support"}}
+	if !grouped.RequiresAccess() || grouped.AccessPermission() != "ro" {
+		t.Errorf("expected restricted route with default ro, got %q", grouped.AccessPermission())
+	}
+
+	queued := plugin.RouteSpec{Method: "POST", Path: "/api/plugins/queued", Queues: []string{"Raw"}, Permission: "rw"}
+	if err := queued.ValidateAccess(); err != nil || queued.AccessPermission() != "rw" {
+		t.Errorf("expected valid rw route, got %v", err)
+	}
+
+	for _, bad := range []plugin.RouteSpec{
+		{Method: "GET", Path: "/a", Groups: []string{"support"}, Permission: "admin"},
+		{Method: "GET", Path: "/b", Permission: "rw"},
+		{Method: "GET", Path: "/c", Roles: []string{"admin"}},
+		{Method: "GET", Path: "/d", Roles: []string{"public"}, Scope: "tickets:read"},
+		{Method: "GET", Path: "/e", Roles: []string{"customer"}, Groups: []string{"support"}},
+		{Method: "GET", Path: "/f", RateLimit: &plugin.RouteRateLimit{}},
+		{Method: "POST", Path: "/g", Public: true, Roles: []string{"agent"}},
+		{Method: "POST", Path: "/h", Public: true, Middleware: []string{"auth"}},
+	} {
+		if bad.ValidateAccess() == nil {
+			t.Errorf("expected validation error for %+v", bad)
+		}
+	}
+}
+
+func TestRouteSpecCallers(t *testing.T) {
+	route := plugin.RouteSpec{Method: "GET", Path: "/api/plugins/portal", Roles: []string{"agent", "customer"},
+		RateLimit: &plugin.RouteRateLimit{Requests: 10}}
+	if err := route.ValidateAccess(); err != nil {
+		t.Fatalf("expected a valid route, got %v", err)
+	}
+	if !route.AllowsRole(plugin.RouteRoleCustomer) || route.AllowsRole(plugin.RouteRolePublic) || route.IsPublic() {
+		t.Errorf("unexpected roles of %+v", route)
+	}
+	if w := route.RateLimit.Window(); w != time.Minute {
+		t.Errorf("expected the default one minute window, got %s", w)
+	}
+	if w := (plugin.RouteRateLimit{Requests: 1, WindowSec: 5}).Window(); w != 5*time.Second {
+		t.Errorf("expected a five second window, got %s", w)
+	}
+}
+
+func TestPublicRoutePolicy(t *testing.T) {
+	policy := plugin.PublicRoutePolicy{AllowIPs: []string{"192.0.2.0/24", "2001:db8::1"}}
+	for ip, want := range map[string]bool{
+		"192.0.2.55":  true,
+		"2001:db8::1": true,
+		"198.51.100.": false,
+		"203.0.113.9": false,
+	} {
+		if got := policy.AllowsIP(ip); got != want {
+			t.Errorf("AllowsIP(%q) = %v, want %v", ip, got, want)
+		}
+	}
+	if !(plugin.PublicRoutePolicy{}).AllowsIP("203.0.113.9") {
+		t.Error("expected an empty allowlist to allow any client")
+	}
+
+	hook := plugin.RouteSpec{Method: "POST", Path: "/hook", Public: true}
+	if l := policy.RouteRateLimit(hook); l.Requests != plugin.DefaultPublicRouteRateLimit || l.Window() != time.Minute {
+		t.Errorf("expected the default public rate limit, got %+v", l)
+	}
+	if l := (plugin.PublicRoutePolicy{RateLimit: 5}).RouteRateLimit(hook); l.Requests != 5 {
+		t.Errorf("expected the policy rate limit, got %+v", l)
+	}
+	hook.RateLimit = &plugin.RouteRateLimit{Requests: 100, WindowSec: 10}
+	if l := policy.RouteRateLimit(hook); l.Requests != 100 || l.WindowSec != 10 {
+		t.Errorf("expected the route's own rate limit, got %+v", l)
+	}
+
+	mgr := plugin.NewManager(plugin.NewProdHostAPI(plugin.WithPluginResourcePolicy("hooks", plugin.ResourcePolicy{PublicRoutes: &policy})))
+	if mgr.PublicRoutes("hooks") == nil || mgr.PublicRoutes("other") != nil {
+		t.Error("expected only the granted plugin to have public routes")
+	}
+}
+
 func TestPluginManagerWidgets(t *testing.T) {
 	ctx := context.Background()
 	host := &mockHostAPI{}
@@ -368,6 +477,32 @@ func TestPluginManagerWidgets(t *testing.T) {
 	}
 }
 
+func TestPluginManagerWidgetIsolation(t *testing.T) {
+	ctx := context.Background()
+	host := plugin.NewProdHostAPI(plugin.WithPluginResourcePolicy("hello",
+		plugin.ResourcePolicy{WidgetIsolation: plugin.WidgetIsolationIframe}))
+	mgr := plugin.NewManager(host)
+	if err := mgr.Register(ctx, example.NewHelloPlugin()); err != nil {
+		t.Fatalf("register failed: %v", err)
+	}
+
+	widgets := mgr.Widgets("dashboard")
+	if len(widgets) == 0 {
+		t.Fatal("expected hello dashboard widget")
+	}
+	if widgets[0].Isolation != plugin.WidgetIsolationIframe {
+		t.Errorf("expected iframe isolation from policy, got %q", widgets[0].Isolation)
+	}
+	if got := mgr.WidgetIsolation("other"); got != plugin.WidgetIsolationInline {
+		t.Errorf("expected inline for plugin without policy, got %q", got)
+	}
+
+	// Hosts without resource policies always render inline
+	if got := plugin.NewManager(&mockHostAPI{}).WidgetIsolation("hello"); got != plugin.WidgetIsolationInline {
+		t.Errorf("expected inline without policy host, got %q", got)
+	}
+}
+
 func TestPluginManagerShutdownAll(t *testing.T) {
 	ctx := context.Background()
 	host := &mockHostAPI{}
@@ -555,6 +690,36 @@ func TestPluginManagerEnableDisable(t *testing.T) {
 	})
 }
 
+func TestPluginManagerLifecycleListener(t *testing.T) {
+	ctx := context.Background()
+	mgr := plugin.NewManager(&mockHostAPI{})
+
+	var got []string
+	mgr.SetLifecycleListener(func(name, event string) {
+		got = append(got, name+":"+event)
+	})
+
+	if err := mgr.Register(ctx, &mockPlugin{}); err != nil {
+		t.Fatalf("register failed: %v", err)
+	}
+	_ = mgr.Disable("mock-plugin")
+	_ = mgr.Enable("mock-plugin")
+	_ = mgr.Disable("nonexistent")
+	if err := mgr.Unregister(ctx, "mock-plugin"); err != nil {
+		t.Fatalf("unregister failed: %v", err)
+	}
+
+	want := []string{
+		"mock-plugin:" + plugin.LifecycleRegistered,
+		"mock-plugin:" + plugin.LifecycleDisabled,
+		"mock-plugin:" + plugin.LifecycleEnabled,
+		"mock-plugin:" + plugin.LifecycleUnregistered,
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("events = %v, want %v", got, want)
+	}
+}
+
 // mockPluginWithTemplates has template overrides
 type mockPluginWithTemplates struct{}
 
@@ -789,3 +954,161 @@ func TestPluginManagerWithLazyLoader(t *testing.T) {
 		}
 	})
 }
+
+// adminPagesPlugin declares admin pages, one of them invalid.
+type adminPagesPlugin struct{ mockPlugin }
+
+func (m *adminPagesPlugin) GKRegister() plugin.GKRegistration {
+	return plugin.GKRegistration{
+		Name: "jira-sync",
+		AdminPages: []plugin.AdminPageSpec{
+			{ID: "mapping", Title: "Field mapping", Handler: "mapping_page", Order: 2},
+			{ID: "settings", Title: "Jira Sync", Handler: "settings_page", Order: 1},
+			{ID: "Bad Page", Title: "Invalid", Handler: "bad_page"},
+		},
+	}
+}
+
+func TestPluginManagerAdminPages(t *testing.T) {
+	ctx := context.Background()
+	mgr := plugin.NewManager(&mockHostAPI{})
+	if err := mgr.Register(ctx, &adminPagesPlugin{}); err != nil {
+		t.Fatalf("plugin with an invalid admin page should still register: %v", err)
+	}
+
+	pages := mgr.AdminPages()
+	if len(pages) != 2 {
+		t.Fatalf("expected 2 valid admin pages, got %d", len(pages))
+	}
+	if pages[0].ID != "settings" || pages[1].ID != "mapping" {
+		t.Errorf("expected pages in order, got %s, %s", pages[0].ID, pages[1].ID)
+	}
+	if got := pages[0].URL(); got != "/admin/plugins/jira-sync/pages/settings" {
+		t.Errorf("unexpected page URL %s", got)
+	}
+
+	if _, ok := mgr.AdminPage("jira-sync", "mapping"); !ok {
+		t.Error("expected mapping page")
+	}
+	if _, ok := mgr.AdminPage("jira-sync", "Bad Page"); ok {
+		t.Error("invalid page should not be served")
+	}
+
+	mgr.Disable("jira-sync")
+	if len(mgr.AdminPages()) != 0 {
+		t.Error("disabled plugins should have no admin pages")
+	}
+
+	// Iframe-isolated plugins cannot run pages in the admin's session
+	host := plugin.NewProdHostAPI(plugin.WithPluginResourcePolicy("jira-sync",
+		plugin.ResourcePolicy{WidgetIsolation: plugin.WidgetIsolationIframe}))
+	isolated := plugin.NewManager(host)
+	if err := isolated.Register(ctx, &adminPagesPlugin{}); err != nil {
+		t.Fatalf("register failed: %v", err)
+	}
+	if len(isolated.AdminPages()) != 0 {
+		t.Error("iframe-isolated plugins should have no admin pages")
+	}
+}
+
+func TestAdminPageSpecValidate(t *testing.T) {
+	valid := plugin.AdminPageSpec{ID: "settings", Handler: "settings_page", Icon: "fa-gear"}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("expected valid page, got %v", err)
+	}
+	for _, spec := range []plugin.AdminPageSpec{
+		{ID: "", Handler: "h"},
+		{ID: "../x", Handler: "h"},
+		{ID: "settings"},
+		{ID: "settings", Handler: "h", Icon: `fa-gear" onclick="x`},
+	} {
+		if err := spec.Validate(); err == nil {
+			t.Errorf("expected %+v to be invalid", spec)
+		}
+	}
+}
+
+// blockingPlugin waits in every call until its context is done.
+type blockingPlugin struct{ name string }
+
+func (p *blockingPlugin) GKRegister() plugin.GKRegistration {
+	return plugin.GKRegistration{Name: p.name, Version: "1.0.0"}
+}
+
+func (p *blockingPlugin) Init(ctx context.Context, host plugin.HostAPI) error { return nil }
+
+func (p *blockingPlugin) Call(ctx context.Context, fn string, args json.RawMessage) (json.RawMessage, error) {
+	<-ctx.Done()
+	return nil, fmt.Errorf("gk_call failed: %w", ctx.Err())
+}
+
+func (p *blockingPlugin) Shutdown(ctx context.Context) error { return nil }
+
+func TestPluginManagerCallTimeout(t *testing.T) {
+	ctx := context.Background()
+	host := plugin.NewProdHostAPI(plugin.WithPluginResourcePolicy("slow", plugin.ResourcePolicy{CallTimeout: 50 * time.Millisecond}))
+	mgr := plugin.NewManager(host)
+	if err := mgr.Register(ctx, &blockingPlugin{name: "slow"}); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+	_, err := mgr.Call(ctx, "slow", "report", nil)
+	var timeout *plugin.PluginTimeoutError
+	if !errors.As(err, &timeout) {
+		t.Fatalf("expected a PluginTimeoutError, got %v", err)
+	}
+	if timeout.PluginName != "slow" || timeout.Function != "report" || timeout.Timeout != 50*time.Millisecond {
+		t.Errorf("unexpected timeout error %+v", timeout)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Error("expected the timeout to match context.DeadlineExceeded")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("call was not cut off at the policy timeout, took %s", elapsed)
+	}
+
+	// A shorter deadline of the caller wins
+	callerCtx, cancel := context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	_, err = mgr.Call(callerCtx, "slow", "report", nil)
+	if !errors.As(err, &timeout) || timeout.Timeout > 10*time.Millisecond {
+		t.Errorf("expected the caller's deadline to apply, got %v", err)
+	}
+
+	// Cancellation by the caller is not a timeout
+	canceled, cancelNow := context.WithCancel(ctx)
+	cancelNow()
+	_, err = mgr.Call(canceled, "slow", "report", nil)
+	if errors.As(err, &timeout) || !errors.Is(err, context.Canceled) {
+		t.Errorf("expected a cancellation error, got %v", err)
+	}
+}
+
+// pooledPlugin reports runtime statistics like a WASM plugin.
+type pooledPlugin struct{ blockingPlugin }
+
+func (p *pooledPlugin) Stats() plugin.PluginStats {
+	return plugin.PluginStats{Pool: &plugin.PoolStats{Min: 1, Max: 4, Instances: 2, InUse: 1}}
+}
+
+func TestPluginManagerStats(t *testing.T) {
+	ctx := context.Background()
+	mgr := plugin.NewManager(&mockHostAPI{})
+	if err := mgr.Register(ctx, &pooledPlugin{blockingPlugin{name: "pooled"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := mgr.Register(ctx, example.NewHelloPlugin()); err != nil {
+		t.Fatal(err)
+	}
+
+	if st := mgr.Stats("pooled"); st.Pool == nil || st.Pool.Instances != 2 || st.Pool.InUse != 1 {
+		t.Errorf("expected the plugin's pool stats, got %+v", st)
+	}
+	if st := mgr.Stats("hello"); st.Pool != nil {
+		t.Errorf("expected no pool stats for an in-process plugin, got %+v", st.Pool)
+	}
+	if st := mgr.Stats("missing"); st.Pool != nil {
+		t.Errorf("expected empty stats for an unknown plugin, got %+v", st)
+	}
+}
@@ -134,6 +134,10 @@ func ExtractPlugin(packagePath, targetDir string) (*PluginPackage, error) {
 		return nil, fmt.Errorf("manifest missing required 'name' field")
 	}
 
+	if err := plugin.ValidateMigrations(pkg.Manifest.Name, pkg.Manifest.Migrations); err != nil {
+		return nil, fmt.Errorf("invalid migrations: %w", err)
+	}
+
 	// Create plugin directory
 	pluginDir := filepath.Join(targetDir, pkg.Manifest.Name)
 	if err := os.MkdirAll(pluginDir, 0755); err != nil {
@@ -226,6 +230,10 @@ func ValidatePackage(packagePath string) (*plugin.GKRegistration, error) {
 		return nil, fmt.Errorf("package missing .wasm file")
 	}
 
+	if err := plugin.ValidateMigrations(manifest.Name, manifest.Migrations); err != nil {
+		return nil, fmt.Errorf("invalid migrations: %w", err)
+	}
+
 	return &manifest, nil
 }
 
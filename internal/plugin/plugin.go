@@ -11,6 +11,9 @@ package plugin
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"regexp"
+	"time"
 )
 
 // Plugin is the unified interface for WASM and gRPC plugins.
@@ -49,10 +52,12 @@ type GKRegistration struct {
 	Routes     []RouteSpec     `json:"routes,omitempty"`      // HTTP routes to register
 	MenuItems  []MenuItemSpec  `json:"menu_items,omitempty"`  // navigation menu entries
 	Widgets    []WidgetSpec    `json:"widgets,omitempty"`     // dashboard widgets
+	AdminPages []AdminPageSpec `json:"admin_pages,omitempty"` // settings pages under Admin
 	Jobs       []JobSpec       `json:"jobs,omitempty"`        // scheduled/cron tasks
 	Templates  []TemplateSpec  `json:"templates,omitempty"`   // template overrides/additions
 	I18n       *I18nSpec       `json:"i18n,omitempty"`        // translations provided by plugin
 	ErrorCodes []ErrorCodeSpec `json:"error_codes,omitempty"` // API error codes provided by plugin
+	Migrations []MigrationSpec `json:"migrations,omitempty"`  // schema migrations for plugin-owned tables
 
 	// Requirements
 	MinHostVersion string   `json:"min_host_version,omitempty"` // minimum GoatFlow version
@@ -66,6 +71,116 @@ type RouteSpec struct {
 	Handler     string   `json:"handler"`                // plugin function to call
 	Middleware  []string `json:"middleware,omitempty"`   // middleware chain, e.g. ["auth", "admin"]
 	Description string   `json:"description,omitempty"`  // for documentation
+
+	// Access restrictions, enforced by the host when the route is mounted.
+	// An agent needs Permission on at least one listed group, or on the
+	// group of at least one listed queue. Admins always pass.
+	Groups     []string `json:"groups,omitempty"`     // group names, e.g. ["support"]
+	Queues     []string `json:"queues,omitempty"`     // queue names, e.g. ["Raw", "Junk"]
+	Permission string   `json:"permission,omitempty"` // permission key: ro (default), rw, create, move_into, note, owner, priority
+
+	// Caller restrictions, also enforced by the host. Routes require login
+	// unless Public is set (or Roles include public), which the host only
+	// honors for plugins whose resource policy grants public routes. Roles
+	// lists who may call the route. Scope is the API token scope the route
+	// requires, a core scope or one of the plugin's own
+	// "plugin:<name>:<action>" scopes, and lets API tokens call the route.
+	Public    bool            `json:"public,omitempty"`     // e.g. webhook receivers
+	Roles     []string        `json:"roles,omitempty"`      // agent, customer, public
+	Scope     string          `json:"scope,omitempty"`      // e.g. "tickets:read" or "plugin:stats:read"
+	RateLimit *RouteRateLimit `json:"rate_limit,omitempty"` // per caller
+}
+
+// RouteRateLimit limits how often one caller (user, API token or, for
+// anonymous requests, client IP) may call a route.
+type RouteRateLimit struct {
+	Requests  int `json:"requests"`             // allowed per window
+	WindowSec int `json:"window_sec,omitempty"` // default 60
+}
+
+// Window returns the rate limit window.
+func (l RouteRateLimit) Window() time.Duration {
+	if l.WindowSec <= 0 {
+		return time.Minute
+	}
+	return time.Duration(l.WindowSec) * time.Second
+}
+
+// Roles a route may allow.
+const (
+	RouteRoleAgent    = "agent"
+	RouteRoleCustomer = "customer"
+	RouteRolePublic   = "public" // no login required
+)
+
+// RouteAccessPermissions are the permission keys a route may require.
+var RouteAccessPermissions = []string{"ro", "move_into", "create", "note", "owner", "priority", "rw"}
+
+// AllowsRole reports whether the route's Roles include role. Routes without
+// Roles leave the role to their middleware.
+func (r RouteSpec) AllowsRole(role string) bool {
+	for _, allowed := range r.Roles {
+		if allowed == role {
+			return true
+		}
+	}
+	return false
+}
+
+// IsPublic reports whether the route declares it may be called without
+// logging in.
+func (r RouteSpec) IsPublic() bool {
+	return r.Public || r.AllowsRole(RouteRolePublic)
+}
+
+// RequiresAccess reports whether the route is restricted to groups or queues.
+func (r RouteSpec) RequiresAccess() bool {
+	return len(r.Groups) > 0 || len(r.Queues) > 0
+}
+
+// AccessPermission returns the permission key checked for Groups and Queues.
+func (r RouteSpec) AccessPermission() string {
+	if r.Permission == "" {
+		return "ro"
+	}
+	return r.Permission
+}
+
+// ValidateAccess checks the route's access restriction settings.
+func (r RouteSpec) ValidateAccess() error {
+	for _, role := range r.Roles {
+		switch role {
+		case RouteRoleAgent, RouteRoleCustomer, RouteRolePublic:
+		default:
+			return fmt.Errorf("route %s %s: unknown role %q", r.Method, r.Path, role)
+		}
+	}
+	if r.IsPublic() {
+		if r.RequiresAccess() || r.Scope != "" || len(r.Middleware) > 0 {
+			return fmt.Errorf("route %s %s: public route cannot require login, groups, queues or a scope", r.Method, r.Path)
+		}
+		if len(r.Roles) > 0 && !r.AllowsRole(RouteRolePublic) {
+			return fmt.Errorf("route %s %s: public route cannot restrict roles", r.Method, r.Path)
+		}
+	}
+	if r.RequiresAccess() && len(r.Roles) > 0 && !r.AllowsRole(RouteRoleAgent) {
+		return fmt.Errorf("route %s %s: groups and queues restrict agents but agents are not allowed", r.Method, r.Path)
+	}
+	if r.RateLimit != nil && (r.RateLimit.Requests <= 0 || r.RateLimit.WindowSec < 0) {
+		return fmt.Errorf("route %s %s: rate limit needs a positive number of requests", r.Method, r.Path)
+	}
+	if r.Permission == "" {
+		return nil
+	}
+	if !r.RequiresAccess() {
+		return fmt.Errorf("route %s %s: permission set without groups or queues", r.Method, r.Path)
+	}
+	for _, p := range RouteAccessPermissions {
+		if r.Permission == p {
+			return nil
+		}
+	}
+	return fmt.Errorf("route %s %s: unknown permission %q", r.Method, r.Path, r.Permission)
 }
 
 // MenuItemSpec defines a navigation menu entry.
@@ -91,6 +206,70 @@ type WidgetSpec struct {
 	Order       int    `json:"order,omitempty"`       // sort order within location
 	Refreshable bool   `json:"refreshable,omitempty"` // can be refreshed via AJAX
 	RefreshSec  int    `json:"refresh_sec,omitempty"` // auto-refresh interval
+	CacheSec    int    `json:"cache_sec,omitempty"`   // how long the host reuses rendered HTML
+}
+
+// Widget refresh limits. Dashboards poll no more often than
+// MinWidgetRefreshSec, whatever the manifest asks for.
+const (
+	DefaultWidgetRefreshSec = 60
+	MinWidgetRefreshSec     = 10
+)
+
+// RefreshInterval returns how often dashboards re-fetch the widget, or zero
+// when it is not refreshable.
+func (w WidgetSpec) RefreshInterval() time.Duration {
+	if !w.Refreshable {
+		return 0
+	}
+	sec := w.RefreshSec
+	if sec <= 0 {
+		sec = DefaultWidgetRefreshSec
+	}
+	return time.Duration(max(sec, MinWidgetRefreshSec)) * time.Second
+}
+
+// CacheTTL returns how long the host serves the widget's rendered HTML
+// without calling the plugin: CacheSec when set, else the refresh interval,
+// so every dashboard polling a widget shares one plugin call per interval.
+// Zero disables caching.
+func (w WidgetSpec) CacheTTL() time.Duration {
+	if w.CacheSec > 0 {
+		return time.Duration(w.CacheSec) * time.Second
+	}
+	return w.RefreshInterval()
+}
+
+// AdminPageSpec defines a settings page shown under Admin. The handler
+// returns {"html": ...}, which the host renders inside its layout at
+// /admin/plugins/<plugin>/pages/<id>; form posts to that URL reach the
+// handler after the host's admin and same-origin checks.
+type AdminPageSpec struct {
+	ID          string `json:"id"`                    // URL segment, e.g. "settings"
+	Title       string `json:"title"`                 // page heading and menu label
+	Description string `json:"description,omitempty"` // shown on the admin dashboard card
+	Icon        string `json:"icon,omitempty"`        // Font Awesome icon, e.g. "fa-gear"
+	Handler     string `json:"handler"`               // plugin function that returns the page HTML
+	Order       int    `json:"order,omitempty"`       // sort order among the plugin's pages
+}
+
+var (
+	adminPageIDPattern   = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+	adminPageIconPattern = regexp.MustCompile(`^fa-[a-z0-9-]+$`)
+)
+
+// Validate checks the page ID, handler and icon.
+func (p AdminPageSpec) Validate() error {
+	if !adminPageIDPattern.MatchString(p.ID) {
+		return fmt.Errorf("admin page %q: id must be lowercase letters, digits, - or _", p.ID)
+	}
+	if p.Handler == "" {
+		return fmt.Errorf("admin page %q: handler is required", p.ID)
+	}
+	if p.Icon != "" && !adminPageIconPattern.MatchString(p.Icon) {
+		return fmt.Errorf("admin page %q: unknown icon %q", p.ID, p.Icon)
+	}
+	return nil
 }
 
 // JobSpec defines a scheduled/cron task.
@@ -105,9 +284,11 @@ type JobSpec struct {
 
 // TemplateSpec defines a template the plugin provides.
 type TemplateSpec struct {
-	Name     string `json:"name"`               // template name, e.g. "stats/dashboard.html"
-	Path     string `json:"path"`               // path within plugin package
-	Override bool   `json:"override,omitempty"` // if true, overrides host template of same name
+	Name     string   `json:"name"`               // template name, e.g. "stats/dashboard.html"
+	Path     string   `json:"path"`               // path within plugin package
+	Override bool     `json:"override,omitempty"` // if true, overrides host template of same name
+	Source   string   `json:"source,omitempty"`   // pongo2 source rendered by the host instead of calling the plugin
+	Blocks   []string `json:"blocks,omitempty"`   // host template blocks the source replaces; empty replaces the whole template
 }
 
 // I18nSpec defines internationalization resources provided by the plugin.
@@ -130,6 +311,17 @@ type ErrorCodeSpec struct {
 	HTTPStatus int    `json:"http_status"` // suggested HTTP status code
 }
 
+// MigrationSpec defines a schema migration for the plugin's own tables.
+// Tables must live in the plugin namespace: write them as "{{prefix}}name",
+// which the host expands to SchemaPrefix(plugin) (e.g. "plg_stats_name").
+// Migrations are applied in Version order on install and upgrade.
+type MigrationSpec struct {
+	Version     int    `json:"version"`               // positive, strictly increasing
+	Description string `json:"description,omitempty"` // human-readable summary
+	Up          string `json:"up"`                    // SQL applied on install/upgrade
+	Down        string `json:"down,omitempty"`        // SQL reverting Up; defaults to dropping created tables
+}
+
 // HostAPI is the interface plugins use to access host services.
 // Passed to Plugin.Init() - plugins store this for later use.
 type HostAPI interface {
@@ -137,11 +329,23 @@ type HostAPI interface {
 	DBQuery(ctx context.Context, query string, args ...any) ([]map[string]any, error)
 	DBExec(ctx context.Context, query string, args ...any) (int64, error)
 
+	// Database transactions, bounded by the plugin's ResourcePolicy.
+	// DBQuery and DBExec join a transaction when the context carries its ID (WithTxID).
+	DBBegin(ctx context.Context, dbName string) (string, error)
+	DBCommit(ctx context.Context, txID string) error
+	DBRollback(ctx context.Context, txID string) error
+
 	// Cache
 	CacheGet(ctx context.Context, key string) ([]byte, bool, error)
 	CacheSet(ctx context.Context, key string, value []byte, ttlSeconds int) error
 	CacheDelete(ctx context.Context, key string) error
 
+	// Files, kept per plugin in the host's attachment storage and bounded by
+	// the plugin's ResourcePolicy
+	FilePut(ctx context.Context, path string, content []byte) error
+	FileGet(ctx context.Context, path string) ([]byte, bool, error)
+	FileDelete(ctx context.Context, path string) error
+
 	// HTTP (outbound)
 	HTTPRequest(ctx context.Context, method, url string, headers map[string]string, body []byte) (int, []byte, error)
 
@@ -154,6 +358,9 @@ type HostAPI interface {
 	// Config
 	ConfigGet(ctx context.Context, key string) (string, error)
 
+	// Feature flags: whether a flag is on for a user (0 for no user)
+	FeatureEnabled(ctx context.Context, flag string, userID int) (bool, error)
+
 	// i18n
 	Translate(ctx context.Context, key string, args ...any) string
 
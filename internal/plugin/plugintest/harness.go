@@ -0,0 +1,280 @@
+package plugintest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/goatkit/goatflow/internal/plugin"
+)
+
+// HostScriptFile is the file in a testdata folder holding the HostScript
+// shared by all cases. Every other *.json file holds one case or an array
+// of cases.
+const HostScriptFile = "host.json"
+
+// Case calls one handler with a sample payload and checks what it returns
+// and which host calls it made.
+type Case struct {
+	Name        string          `json:"name,omitempty"` // defaults to the file name
+	Handler     string          `json:"handler"`
+	Args        json.RawMessage `json:"args,omitempty"`
+	Host        HostScript      `json:"host,omitempty"`         // merged over the suite's host.json
+	Expect      json.RawMessage `json:"expect,omitempty"`       // must be contained in the result
+	ExpectError string          `json:"expect_error,omitempty"` // substring of the expected error
+	ExpectCalls []HostCall      `json:"expect_calls,omitempty"` // host calls made in this order, each contained in a recorded one
+}
+
+// Suite is a testdata folder loaded by LoadSuite.
+type Suite struct {
+	Host  HostScript
+	Cases []Case
+}
+
+// LoadSuite reads host.json and the case files of a testdata folder.
+func LoadSuite(dir string) (*Suite, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	suite := &Suite{}
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		if filepath.Base(f) == HostScriptFile {
+			if err := decodeStrict(data, &suite.Host); err != nil {
+				return nil, fmt.Errorf("%s: %w", f, err)
+			}
+			continue
+		}
+		var cases []Case
+		if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+			err = decodeStrict(data, &cases)
+		} else {
+			var c Case
+			err = decodeStrict(data, &c)
+			cases = []Case{c}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f, err)
+		}
+		base := strings.TrimSuffix(filepath.Base(f), ".json")
+		for i := range cases {
+			if cases[i].Handler == "" {
+				return nil, fmt.Errorf("%s: case %d has no handler", f, i+1)
+			}
+			if cases[i].Name == "" {
+				cases[i].Name = base
+				if len(cases) > 1 {
+					cases[i].Name = fmt.Sprintf("%s#%d", base, i+1)
+				}
+			}
+		}
+		suite.Cases = append(suite.Cases, cases...)
+	}
+	if len(suite.Cases) == 0 {
+		return nil, fmt.Errorf("no test cases in %s", dir)
+	}
+	return suite, nil
+}
+
+// decodeStrict rejects unknown fields so a misspelt expectation fails
+// instead of passing unchecked.
+func decodeStrict(data []byte, v any) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	return dec.Decode(v)
+}
+
+// Result is the outcome of one case; Err is nil when it passed.
+type Result struct {
+	Case     Case
+	Output   json.RawMessage
+	Calls    []HostCall
+	Duration time.Duration
+	Err      error
+}
+
+// Passed reports whether the case passed.
+func (r Result) Passed() bool { return r.Err == nil }
+
+// Run calls the cases one after another on a plugin initialised with host,
+// resetting host to the suite script merged with each case's own.
+func (s *Suite) Run(ctx context.Context, p plugin.Plugin, host *MockHost) []Result {
+	results := make([]Result, 0, len(s.Cases))
+	for _, c := range s.Cases {
+		host.Reset(s.Host.Merge(c.Host))
+		args := c.Args
+		if len(args) == 0 {
+			args = json.RawMessage(`{}`)
+		}
+		start := time.Now()
+		out, err := p.Call(ctx, c.Handler, args)
+		r := Result{Case: c, Output: out, Calls: host.Calls(), Duration: time.Since(start)}
+		r.Err = check(c, out, err, r.Calls)
+		results = append(results, r)
+	}
+	return results
+}
+
+func check(c Case, out json.RawMessage, callErr error, calls []HostCall) error {
+	switch {
+	case c.ExpectError != "" && callErr == nil:
+		return fmt.Errorf("expected an error containing %q, got %s", c.ExpectError, out)
+	case c.ExpectError != "" && !strings.Contains(callErr.Error(), c.ExpectError):
+		return fmt.Errorf("expected an error containing %q, got %q", c.ExpectError, callErr)
+	case c.ExpectError == "" && callErr != nil:
+		return fmt.Errorf("handler failed: %w", callErr)
+	}
+	if len(c.Expect) > 0 && callErr == nil {
+		var want, got any
+		if err := json.Unmarshal(c.Expect, &want); err != nil {
+			return fmt.Errorf("expect: %w", err)
+		}
+		if err := json.Unmarshal(out, &got); err != nil {
+			return fmt.Errorf("result is not JSON: %s", out)
+		}
+		if err := contains("$", want, got); err != nil {
+			return err
+		}
+	}
+	return checkCalls(c.ExpectCalls, calls)
+}
+
+// checkCalls finds the expected calls among the recorded ones, in order.
+func checkCalls(expected, recorded []HostCall) error {
+	generic := make([]any, len(recorded))
+	for i, call := range recorded {
+		generic[i] = toGeneric(call)
+	}
+	next := 0
+	for i, want := range expected {
+		w := toGeneric(want)
+		found := false
+		for ; next < len(generic); next++ {
+			if contains("", w, generic[next]) == nil {
+				found, next = true, next+1
+				break
+			}
+		}
+		if !found {
+			data, _ := json.Marshal(want)
+			return fmt.Errorf("expect_calls[%d]: no matching host call %s", i, data)
+		}
+	}
+	return nil
+}
+
+// toGeneric turns a value into what json.Unmarshal produces for it, so
+// recorded Go values compare with expectations read from files.
+func toGeneric(v any) any {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	var out any
+	if err := json.Unmarshal(data, &out); err != nil {
+		return v
+	}
+	return out
+}
+
+// contains reports whether got contains want: objects may have extra keys,
+// arrays must have the same length and scalars must be equal.
+func contains(path string, want, got any) error {
+	switch w := want.(type) {
+	case map[string]any:
+		g, ok := got.(map[string]any)
+		if !ok {
+			return mismatch(path, want, got)
+		}
+		keys := make([]string, 0, len(w))
+		for k := range w {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			gv, ok := g[k]
+			if !ok {
+				return fmt.Errorf("%s.%s: missing", path, k)
+			}
+			if err := contains(path+"."+k, w[k], gv); err != nil {
+				return err
+			}
+		}
+		return nil
+	case []any:
+		g, ok := got.([]any)
+		if !ok || len(g) != len(w) {
+			return mismatch(path, want, got)
+		}
+		for i := range w {
+			if err := contains(fmt.Sprintf("%s[%d]", path, i), w[i], g[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		if !reflect.DeepEqual(want, got) {
+			return mismatch(path, want, got)
+		}
+		return nil
+	}
+}
+
+func mismatch(path string, want, got any) error {
+	w, _ := json.Marshal(want)
+	g, _ := json.Marshal(got)
+	return fmt.Errorf("%s: want %s, got %s", path, w, g)
+}
+
+// DeclaredHandlers lists the handlers a plugin's registration refers to:
+// those of its routes, widgets, admin pages and jobs.
+func DeclaredHandlers(reg plugin.GKRegistration) []string {
+	seen := map[string]bool{}
+	var out []string
+	add := func(h string) {
+		if h != "" && !seen[h] {
+			seen[h] = true
+			out = append(out, h)
+		}
+	}
+	for _, r := range reg.Routes {
+		add(r.Handler)
+	}
+	for _, w := range reg.Widgets {
+		add(w.Handler)
+	}
+	for _, p := range reg.AdminPages {
+		add(p.Handler)
+	}
+	for _, j := range reg.Jobs {
+		add(j.Handler)
+	}
+	return out
+}
+
+// Untested returns the declared handlers no case calls.
+func (s *Suite) Untested(reg plugin.GKRegistration) []string {
+	tested := map[string]bool{}
+	for _, c := range s.Cases {
+		tested[c.Handler] = true
+	}
+	var out []string
+	for _, h := range DeclaredHandlers(reg) {
+		if !tested[h] {
+			out = append(out, h)
+		}
+	}
+	return out
+}
@@ -0,0 +1,125 @@
+package plugintest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/plugin"
+	"github.com/goatkit/goatflow/internal/plugin/example"
+)
+
+// syncPlugin counts open tickets and posts the count to a webhook.
+type syncPlugin struct{ host plugin.HostAPI }
+
+func (p *syncPlugin) GKRegister() plugin.GKRegistration {
+	return plugin.GKRegistration{
+		Name:   "sync",
+		Routes: []plugin.RouteSpec{{Method: "POST", Path: "/sync", Handler: "sync"}},
+		Jobs:   []plugin.JobSpec{{ID: "nightly", Handler: "nightly", Schedule: "0 2 * * *"}},
+	}
+}
+
+func (p *syncPlugin) Init(ctx context.Context, host plugin.HostAPI) error {
+	p.host = host
+	return nil
+}
+
+func (p *syncPlugin) Call(ctx context.Context, fn string, args json.RawMessage) (json.RawMessage, error) {
+	if fn != "sync" {
+		return nil, fmt.Errorf("unknown function: %s", fn)
+	}
+	var req struct {
+		Queue string `json:"queue"`
+	}
+	_ = json.Unmarshal(args, &req)
+	rows, err := p.host.DBQuery(ctx, "SELECT COUNT(*) AS n FROM ticket WHERE queue = ?", req.Queue)
+	if err != nil {
+		return nil, err
+	}
+	body, _ := json.Marshal(map[string]any{"queue": req.Queue, "open": rows[0]["n"]})
+	status, _, err := p.host.HTTPRequest(ctx, "POST", "https://hooks.example.com/sync", nil, body)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(map[string]any{"open": rows[0]["n"], "status": status})
+}
+
+func (p *syncPlugin) Shutdown(ctx context.Context) error { return nil }
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+	}
+	return dir
+}
+
+func TestSuiteRun(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"host.json": `{"db_query": [{"match": "FROM ticket", "rows": [{"n": 3}]}],
+			"http_request": [{"method": "POST", "url": "https://hooks.example.com/", "status": 202}]}`,
+		"sync.json": `{"handler": "sync", "args": {"queue": "Raw"},
+			"expect": {"open": 3, "status": 202},
+			"expect_calls": [{"fn": "db_query", "args": ["Raw"]}, {"fn": "http_request", "body": "{\"open\":3,\"queue\":\"Raw\"}"}]}`,
+		"errors.json": `[
+			{"name": "webhook down", "handler": "sync", "host": {"http_request": [{"url": "https://hooks.example.com/", "error": "connection refused"}]}, "expect_error": "refused"},
+			{"handler": "sync", "expect": {"open": 4}},
+			{"handler": "sync", "expect_error": "nope"}
+		]`,
+	})
+	suite, err := LoadSuite(dir)
+	require.NoError(t, err)
+	require.Len(t, suite.Cases, 4)
+	assert.Equal(t, "errors#2", suite.Cases[1].Name)
+
+	p := &syncPlugin{}
+	host := NewMockHost(suite.Host)
+	require.NoError(t, p.Init(context.Background(), host))
+	results := suite.Run(context.Background(), p, host)
+	require.Len(t, results, 4)
+
+	assert.True(t, results[0].Passed(), "case-level stubs win over host.json: %v", results[0].Err)
+	assert.EqualError(t, results[1].Err, "$.open: want 4, got 3")
+	assert.ErrorContains(t, results[2].Err, `expected an error containing "nope"`)
+	assert.True(t, results[3].Passed(), "%v", results[3].Err)
+	assert.Len(t, results[3].Calls, 2)
+	assert.Equal(t, []string{"nightly"}, suite.Untested(p.GKRegister()))
+}
+
+func TestLoadSuiteRejectsUnknownFields(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"a.json": `{"handler": "x", "expected": {}}`})
+	_, err := LoadSuite(dir)
+	assert.ErrorContains(t, err, "expected")
+
+	_, err = LoadSuite(t.TempDir())
+	assert.ErrorContains(t, err, "no test cases")
+}
+
+func TestMockHostWithExamplePlugin(t *testing.T) {
+	ctx := context.Background()
+	host := NewMockHost(HostScript{})
+	p := example.NewHelloPlugin()
+	require.NoError(t, p.Init(ctx, host))
+	suite := &Suite{Host: HostScript{Translations: map[string]string{"hello_greeting": "Moin, %s!"}}, Cases: []Case{{
+		Name:        "greets",
+		Handler:     "hello",
+		Args:        json.RawMessage(`{"name": "Ann"}`),
+		Expect:      json.RawMessage(`{"message": "Moin, Ann!"}`),
+		ExpectCalls: []HostCall{{"fn": "translate", "key": "hello_greeting"}},
+	}}}
+	results := suite.Run(ctx, p, host)
+	require.Len(t, results, 1)
+	assert.True(t, results[0].Passed(), "%v", results[0].Err)
+	assert.Equal(t, []string{"stats", "widget", "settings_page", "scheduled_hello"}, suite.Untested(p.GKRegister()))
+
+	_, err := host.CallPlugin(ctx, "other", "fn", nil)
+	assert.ErrorContains(t, err, "no plugin_call stub")
+}
@@ -0,0 +1,354 @@
+// Package plugintest runs a built plugin outside GoatFlow: it backs the
+// plugin with a scriptable mock host API and calls its handlers with sample
+// payloads from a testdata folder, checking the results.
+package plugintest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/goatkit/goatflow/internal/plugin"
+)
+
+// HostScript sets what the mock host answers. Stubs are tried in order and
+// the first match wins; db_query without a matching stub returns no rows,
+// http_request and plugin_call without one fail, so a test never reaches
+// the network or another plugin.
+type HostScript struct {
+	Queries      []QueryStub       `json:"db_query,omitempty"`
+	Execs        []ExecStub        `json:"db_exec,omitempty"`
+	HTTP         []HTTPStub        `json:"http_request,omitempty"`
+	Plugins      []PluginStub      `json:"plugin_call,omitempty"`
+	Config       map[string]string `json:"config,omitempty"`
+	Cache        map[string]string `json:"cache,omitempty"`
+	Files        map[string]string `json:"files,omitempty"`
+	Features     []string          `json:"features,omitempty"`
+	Translations map[string]string `json:"translations,omitempty"`
+}
+
+// QueryStub answers db_query calls whose SQL contains Match.
+type QueryStub struct {
+	Match string           `json:"match,omitempty"`
+	Rows  []map[string]any `json:"rows,omitempty"`
+	Error string           `json:"error,omitempty"`
+}
+
+// ExecStub answers db_exec calls whose SQL contains Match.
+type ExecStub struct {
+	Match    string `json:"match,omitempty"`
+	Affected int64  `json:"affected,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// HTTPStub answers http_request calls with the given method (any when
+// empty) to URLs starting with URL. A string Body is sent as is, anything
+// else as JSON.
+type HTTPStub struct {
+	Method string `json:"method,omitempty"`
+	URL    string `json:"url"`
+	Status int    `json:"status,omitempty"`
+	Body   any    `json:"body,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// PluginStub answers plugin_call calls to Plugin's function Function (any
+// when empty).
+type PluginStub struct {
+	Plugin   string          `json:"plugin"`
+	Function string          `json:"function,omitempty"`
+	Result   json.RawMessage `json:"result,omitempty"`
+	Error    string          `json:"error,omitempty"`
+}
+
+// Merge returns s with the stubs and values of override taking precedence.
+func (s HostScript) Merge(override HostScript) HostScript {
+	out := HostScript{
+		Queries:      append(append([]QueryStub{}, override.Queries...), s.Queries...),
+		Execs:        append(append([]ExecStub{}, override.Execs...), s.Execs...),
+		HTTP:         append(append([]HTTPStub{}, override.HTTP...), s.HTTP...),
+		Plugins:      append(append([]PluginStub{}, override.Plugins...), s.Plugins...),
+		Config:       mergeMaps(s.Config, override.Config),
+		Cache:        mergeMaps(s.Cache, override.Cache),
+		Files:        mergeMaps(s.Files, override.Files),
+		Features:     append(append([]string{}, s.Features...), override.Features...),
+		Translations: mergeMaps(s.Translations, override.Translations),
+	}
+	return out
+}
+
+func mergeMaps(base, override map[string]string) map[string]string {
+	out := make(map[string]string, len(base)+len(override))
+	for k, v := range base {
+		out[k] = v
+	}
+	for k, v := range override {
+		out[k] = v
+	}
+	return out
+}
+
+// HostCall is one recorded host API call. Fn is the host function name as
+// a WASM plugin calls it (db_query, http_request, ...); the other keys hold
+// its arguments.
+type HostCall map[string]any
+
+// MockHost is a plugin.HostAPI answering from a HostScript and recording
+// every call.
+type MockHost struct {
+	mu     sync.Mutex
+	script HostScript
+	cache  map[string][]byte
+	files  map[string][]byte
+	calls  []HostCall
+	txSeq  int
+}
+
+var _ plugin.HostAPI = (*MockHost)(nil)
+
+// NewMockHost creates a mock host answering from script.
+func NewMockHost(script HostScript) *MockHost {
+	h := &MockHost{}
+	h.Reset(script)
+	return h
+}
+
+// Reset replaces the script, reloads the cache and files from it and
+// forgets the recorded calls.
+func (h *MockHost) Reset(script HostScript) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.script = script
+	h.cache = make(map[string][]byte, len(script.Cache))
+	for k, v := range script.Cache {
+		h.cache[k] = []byte(v)
+	}
+	h.files = make(map[string][]byte, len(script.Files))
+	for k, v := range script.Files {
+		h.files[k] = []byte(v)
+	}
+	h.calls = nil
+}
+
+// Calls returns the host calls recorded since the last Reset.
+func (h *MockHost) Calls() []HostCall {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]HostCall(nil), h.calls...)
+}
+
+func (h *MockHost) record(fn string, kv ...any) {
+	call := HostCall{"fn": fn}
+	for i := 0; i+1 < len(kv); i += 2 {
+		call[kv[i].(string)] = kv[i+1]
+	}
+	h.calls = append(h.calls, call)
+}
+
+func stubError(msg string) error {
+	if msg == "" {
+		return nil
+	}
+	return fmt.Errorf("%s", msg)
+}
+
+// DBQuery implements plugin.HostAPI.
+func (h *MockHost) DBQuery(ctx context.Context, query string, args ...any) ([]map[string]any, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.record("db_query", "query", query, "args", args, "tx", plugin.TxIDFromContext(ctx))
+	for _, s := range h.script.Queries {
+		if strings.Contains(query, s.Match) {
+			return s.Rows, stubError(s.Error)
+		}
+	}
+	return []map[string]any{}, nil
+}
+
+// DBExec implements plugin.HostAPI.
+func (h *MockHost) DBExec(ctx context.Context, query string, args ...any) (int64, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.record("db_exec", "query", query, "args", args, "tx", plugin.TxIDFromContext(ctx))
+	for _, s := range h.script.Execs {
+		if strings.Contains(query, s.Match) {
+			return s.Affected, stubError(s.Error)
+		}
+	}
+	return 0, nil
+}
+
+// DBBegin implements plugin.HostAPI.
+func (h *MockHost) DBBegin(ctx context.Context, dbName string) (string, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.txSeq++
+	tx := fmt.Sprintf("tx-%d", h.txSeq)
+	h.record("db_begin", "database", dbName, "tx", tx)
+	return tx, nil
+}
+
+// DBCommit implements plugin.HostAPI.
+func (h *MockHost) DBCommit(ctx context.Context, txID string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.record("db_commit", "tx", txID)
+	return nil
+}
+
+// DBRollback implements plugin.HostAPI.
+func (h *MockHost) DBRollback(ctx context.Context, txID string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.record("db_rollback", "tx", txID)
+	return nil
+}
+
+// CacheGet implements plugin.HostAPI.
+func (h *MockHost) CacheGet(ctx context.Context, key string) ([]byte, bool, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.record("cache_get", "key", key)
+	v, ok := h.cache[key]
+	return v, ok, nil
+}
+
+// CacheSet implements plugin.HostAPI.
+func (h *MockHost) CacheSet(ctx context.Context, key string, value []byte, ttlSeconds int) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.record("cache_set", "key", key, "value", string(value), "ttl", ttlSeconds)
+	h.cache[key] = value
+	return nil
+}
+
+// CacheDelete implements plugin.HostAPI.
+func (h *MockHost) CacheDelete(ctx context.Context, key string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.record("cache_delete", "key", key)
+	delete(h.cache, key)
+	return nil
+}
+
+// FilePut implements plugin.HostAPI.
+func (h *MockHost) FilePut(ctx context.Context, path string, content []byte) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.record("file_put", "path", path, "content", string(content))
+	h.files[path] = content
+	return nil
+}
+
+// FileGet implements plugin.HostAPI.
+func (h *MockHost) FileGet(ctx context.Context, path string) ([]byte, bool, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.record("file_get", "path", path)
+	v, ok := h.files[path]
+	return v, ok, nil
+}
+
+// FileDelete implements plugin.HostAPI.
+func (h *MockHost) FileDelete(ctx context.Context, path string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.record("file_delete", "path", path)
+	delete(h.files, path)
+	return nil
+}
+
+// HTTPRequest implements plugin.HostAPI.
+func (h *MockHost) HTTPRequest(ctx context.Context, method, url string, headers map[string]string, body []byte) (int, []byte, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.record("http_request", "method", method, "url", url, "headers", headers, "body", string(body))
+	for _, s := range h.script.HTTP {
+		if (s.Method != "" && !strings.EqualFold(s.Method, method)) || !strings.HasPrefix(url, s.URL) {
+			continue
+		}
+		if s.Error != "" {
+			return 0, nil, stubError(s.Error)
+		}
+		status := s.Status
+		if status == 0 {
+			status = 200
+		}
+		switch b := s.Body.(type) {
+		case nil:
+			return status, nil, nil
+		case string:
+			return status, []byte(b), nil
+		default:
+			data, err := json.Marshal(b)
+			return status, data, err
+		}
+	}
+	return 0, nil, fmt.Errorf("plugintest: no http_request stub for %s %s", method, url)
+}
+
+// SendEmail implements plugin.HostAPI.
+func (h *MockHost) SendEmail(ctx context.Context, to, subject, body string, html bool) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.record("send_email", "to", to, "subject", subject, "body", body, "html", html)
+	return nil
+}
+
+// Log implements plugin.HostAPI.
+func (h *MockHost) Log(ctx context.Context, level, message string, fields map[string]any) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.record("log", "level", level, "message", message, "fields", fields)
+}
+
+// ConfigGet implements plugin.HostAPI.
+func (h *MockHost) ConfigGet(ctx context.Context, key string) (string, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.record("config_get", "key", key)
+	return h.script.Config[key], nil
+}
+
+// FeatureEnabled implements plugin.HostAPI.
+func (h *MockHost) FeatureEnabled(ctx context.Context, flag string, userID int) (bool, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.record("feature_enabled", "flag", flag, "user_id", userID)
+	for _, f := range h.script.Features {
+		if f == flag {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Translate implements plugin.HostAPI. Keys without a translation come back
+// unchanged.
+func (h *MockHost) Translate(ctx context.Context, key string, args ...any) string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.record("translate", "key", key, "args", args)
+	if v, ok := h.script.Translations[key]; ok {
+		if len(args) > 0 && strings.Contains(v, "%") {
+			return fmt.Sprintf(v, args...)
+		}
+		return v
+	}
+	return key
+}
+
+// CallPlugin implements plugin.HostAPI.
+func (h *MockHost) CallPlugin(ctx context.Context, pluginName, fn string, args json.RawMessage) (json.RawMessage, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.record("plugin_call", "plugin", pluginName, "function", fn, "args", args)
+	for _, s := range h.script.Plugins {
+		if s.Plugin == pluginName && (s.Function == "" || s.Function == fn) {
+			return s.Result, stubError(s.Error)
+		}
+	}
+	return nil, fmt.Errorf("plugintest: no plugin_call stub for %s.%s", pluginName, fn)
+}
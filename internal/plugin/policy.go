@@ -0,0 +1,153 @@
+package plugin
+
+import (
+	"errors"
+	"net"
+	"time"
+)
+
+// ResourcePolicy bounds the host resources a single plugin may consume.
+// Zero values fall back to the corresponding DefaultResourcePolicy value.
+type ResourcePolicy struct {
+	// MaxTxDuration is how long a transaction may stay open before the host
+	// rolls it back.
+	MaxTxDuration time.Duration `json:"max_tx_duration"`
+	// MaxTxStatements caps the statements (queries and execs) run inside one
+	// transaction. Exceeding it rolls the transaction back.
+	MaxTxStatements int `json:"max_tx_statements"`
+	// MaxOpenTx caps the transactions a plugin may hold open at once.
+	MaxOpenTx int `json:"max_open_tx"`
+	// WidgetIsolation selects how the plugin's widget HTML reaches the agent
+	// UI. Untrusted plugins should use WidgetIsolationIframe.
+	WidgetIsolation WidgetIsolation `json:"widget_isolation"`
+	// MaxCacheValueBytes caps the size of a single value stored with
+	// CacheSet.
+	MaxCacheValueBytes int `json:"max_cache_value_bytes"`
+	// CallTimeout bounds a single call into the plugin. A shorter deadline
+	// on the caller's context wins.
+	CallTimeout time.Duration `json:"call_timeout"`
+	// MaxFileBytes caps the size of a single file stored with FilePut.
+	MaxFileBytes int64 `json:"max_file_bytes"`
+	// MaxStorageBytes caps the total size of the files the plugin keeps
+	// with FilePut.
+	MaxStorageBytes int64 `json:"max_storage_bytes"`
+	// PublicRoutes grants the capability to serve routes marked public
+	// without login. Without it, the default, such routes are not mounted.
+	PublicRoutes *PublicRoutePolicy `json:"public_routes,omitempty"`
+}
+
+// DefaultPublicRouteRateLimit is the requests per minute a client IP may
+// send to a public route that declares no rate limit of its own.
+const DefaultPublicRouteRateLimit = 60
+
+// PublicRoutePolicy restricts the public routes of a plugin granted the
+// capability.
+type PublicRoutePolicy struct {
+	// AllowIPs limits public routes to these addresses or CIDR ranges, e.g.
+	// a webhook sender's; empty allows any client.
+	AllowIPs []string `json:"allow_ips,omitempty"`
+	// RateLimit is the requests per minute per client IP for public routes
+	// without a rate limit of their own. Zero uses
+	// DefaultPublicRouteRateLimit.
+	RateLimit int `json:"rate_limit,omitempty"`
+}
+
+// AllowsIP reports whether a client IP may call the plugin's public routes.
+func (p PublicRoutePolicy) AllowsIP(ip string) bool {
+	if len(p.AllowIPs) == 0 {
+		return true
+	}
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, entry := range p.AllowIPs {
+		if allowed := net.ParseIP(entry); allowed != nil {
+			if allowed.Equal(parsed) {
+				return true
+			}
+			continue
+		}
+		if _, network, err := net.ParseCIDR(entry); err == nil && network.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// RouteRateLimit returns the rate limit of a public route: its own, or the
+// policy's per-minute default.
+func (p PublicRoutePolicy) RouteRateLimit(spec RouteSpec) RouteRateLimit {
+	if spec.RateLimit != nil {
+		return *spec.RateLimit
+	}
+	if p.RateLimit > 0 {
+		return RouteRateLimit{Requests: p.RateLimit, WindowSec: 60}
+	}
+	return RouteRateLimit{Requests: DefaultPublicRouteRateLimit, WindowSec: 60}
+}
+
+// WidgetIsolation is the rendering mode for a plugin's widgets.
+type WidgetIsolation string
+
+const (
+	// WidgetIsolationInline inlines widget HTML into the page DOM.
+	WidgetIsolationInline WidgetIsolation = "inline"
+	// WidgetIsolationIframe serves widget HTML as a standalone document in a
+	// sandboxed iframe with an opaque origin. The iframe reports its height
+	// to the parent via postMessage.
+	WidgetIsolationIframe WidgetIsolation = "iframe"
+)
+
+// DefaultResourcePolicy returns the policy applied to plugins without an
+// explicit one.
+func DefaultResourcePolicy() ResourcePolicy {
+	return ResourcePolicy{
+		MaxTxDuration:      10 * time.Second,
+		MaxTxStatements:    100,
+		MaxOpenTx:          2,
+		WidgetIsolation:    WidgetIsolationInline,
+		MaxCacheValueBytes: 1 << 20,
+		CallTimeout:        30 * time.Second,
+		MaxFileBytes:       10 << 20,
+		MaxStorageBytes:    100 << 20,
+	}
+}
+
+// withDefaults fills zero fields from DefaultResourcePolicy.
+func (p ResourcePolicy) withDefaults() ResourcePolicy {
+	d := DefaultResourcePolicy()
+	if p.MaxTxDuration <= 0 {
+		p.MaxTxDuration = d.MaxTxDuration
+	}
+	if p.MaxTxStatements <= 0 {
+		p.MaxTxStatements = d.MaxTxStatements
+	}
+	if p.MaxOpenTx <= 0 {
+		p.MaxOpenTx = d.MaxOpenTx
+	}
+	if p.WidgetIsolation != WidgetIsolationIframe {
+		p.WidgetIsolation = d.WidgetIsolation
+	}
+	if p.MaxCacheValueBytes <= 0 {
+		p.MaxCacheValueBytes = d.MaxCacheValueBytes
+	}
+	if p.CallTimeout <= 0 {
+		p.CallTimeout = d.CallTimeout
+	}
+	if p.MaxFileBytes <= 0 {
+		p.MaxFileBytes = d.MaxFileBytes
+	}
+	if p.MaxStorageBytes <= 0 {
+		p.MaxStorageBytes = d.MaxStorageBytes
+	}
+	return p
+}
+
+// Transaction errors returned by the host API.
+var (
+	ErrTxNotFound       = errors.New("transaction not found or already finished")
+	ErrTxTooManyOpen    = errors.New("too many open transactions")
+	ErrTxStatementLimit = errors.New("transaction statement limit exceeded; transaction rolled back")
+	ErrTxDatabase       = errors.New("query targets a different database than its transaction")
+)
@@ -0,0 +1,390 @@
+package plugin
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/goatkit/goatflow/internal/database"
+)
+
+// SchemaPrefixPlaceholder is replaced by the plugin's SchemaPrefix in
+// migration SQL, e.g. "CREATE TABLE {{prefix}}counters (...)".
+const SchemaPrefixPlaceholder = "{{prefix}}"
+
+// Schema errors returned by migration validation and the host API.
+var (
+	ErrDDLNotAllowed         = errors.New("schema changes are not allowed through db_exec/db_query; declare migrations in the plugin manifest")
+	ErrMigrationOutsideSpace = errors.New("migration touches objects outside the plugin namespace")
+	ErrSchemaNewer           = errors.New("installed plugin schema is newer than the plugin's migrations")
+)
+
+var nonIdentChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// SchemaPrefix returns the table name prefix reserved for a plugin,
+// e.g. "plg_stats_" for plugin "stats".
+func SchemaPrefix(pluginName string) string {
+	name := strings.Trim(nonIdentChars.ReplaceAllString(strings.ToLower(pluginName), "_"), "_")
+	return "plg_" + name + "_"
+}
+
+// ExpandSchemaPrefix replaces SchemaPrefixPlaceholder with the plugin's prefix.
+func ExpandSchemaPrefix(query, pluginName string) string {
+	return strings.ReplaceAll(query, SchemaPrefixPlaceholder, SchemaPrefix(pluginName))
+}
+
+// Statement shapes allowed in plugin migrations. Each captures the object
+// names that must carry the plugin prefix.
+var (
+	identPattern   = "([`\"]?[\\w.]+[`\"]?)"
+	createTableRe  = regexp.MustCompile(`(?is)^CREATE\s+TABLE\s+(?:IF\s+NOT\s+EXISTS\s+)?` + identPattern)
+	createIndexRe  = regexp.MustCompile(`(?is)^CREATE\s+(?:UNIQUE\s+)?INDEX\s+(?:IF\s+NOT\s+EXISTS\s+)?` + identPattern + `\s+ON\s+` + identPattern)
+	alterTableRe   = regexp.MustCompile(`(?is)^ALTER\s+TABLE\s+(?:IF\s+EXISTS\s+)?(?:ONLY\s+)?` + identPattern)
+	renameToRe     = regexp.MustCompile(`(?is)\bRENAME\s+TO\s+` + identPattern)
+	dropTableRe    = regexp.MustCompile(`(?is)^DROP\s+TABLE\s+(?:IF\s+EXISTS\s+)?(.+?)(?:\s+(?:CASCADE|RESTRICT))?$`)
+	dropIndexRe    = regexp.MustCompile(`(?is)^DROP\s+INDEX\s+(?:IF\s+EXISTS\s+)?` + identPattern + `(?:\s+ON\s+` + identPattern + `)?`)
+	insertRe       = regexp.MustCompile(`(?is)^INSERT\s+INTO\s+` + identPattern)
+	updateRe       = regexp.MustCompile(`(?is)^UPDATE\s+` + identPattern)
+	deleteRe       = regexp.MustCompile(`(?is)^DELETE\s+FROM\s+` + identPattern)
+	ddlKeywords    = []string{"CREATE", "ALTER", "DROP", "TRUNCATE", "RENAME"}
+	migrationRules = []*regexp.Regexp{createTableRe, createIndexRe, alterTableRe, dropTableRe, dropIndexRe, insertRe, updateRe, deleteRe}
+)
+
+// ValidateMigrations checks that a plugin's migrations are ordered and only
+// create or modify objects inside the plugin namespace.
+func ValidateMigrations(pluginName string, migrations []MigrationSpec) error {
+	prev := 0
+	for _, m := range migrations {
+		if m.Version <= prev {
+			return fmt.Errorf("migration version %d must be positive and greater than %d", m.Version, prev)
+		}
+		prev = m.Version
+		if strings.TrimSpace(m.Up) == "" {
+			return fmt.Errorf("migration %d: up SQL is required", m.Version)
+		}
+		for _, q := range []string{m.Up, m.Down} {
+			if err := validateMigrationSQL(pluginName, ExpandSchemaPrefix(q, pluginName)); err != nil {
+				return fmt.Errorf("migration %d: %w", m.Version, err)
+			}
+		}
+	}
+	return nil
+}
+
+func validateMigrationSQL(pluginName, query string) error {
+	prefix := SchemaPrefix(pluginName)
+	for _, stmt := range splitSQLStatements(query) {
+		var names []string
+		for _, re := range migrationRules {
+			match := re.FindStringSubmatch(stmt)
+			if match == nil {
+				continue
+			}
+			if re == dropTableRe {
+				names = strings.Split(match[1], ",")
+			} else {
+				names = match[1:]
+			}
+			break
+		}
+		if names == nil {
+			return fmt.Errorf("%w: unsupported statement %q", ErrMigrationOutsideSpace, firstWords(stmt, 3))
+		}
+		if m := renameToRe.FindStringSubmatch(stmt); m != nil {
+			names = append(names, m[1])
+		}
+		for _, name := range names {
+			name = strings.Trim(strings.TrimSpace(name), "`\"")
+			if name == "" {
+				continue
+			}
+			if strings.Contains(name, ".") || !strings.HasPrefix(strings.ToLower(name), prefix) {
+				return fmt.Errorf("%w: %q does not start with %q", ErrMigrationOutsideSpace, name, prefix)
+			}
+		}
+	}
+	return nil
+}
+
+// createdTables returns the tables created by a migration, in creation order.
+func createdTables(query string) []string {
+	var tables []string
+	for _, stmt := range splitSQLStatements(query) {
+		if m := createTableRe.FindStringSubmatch(stmt); m != nil {
+			tables = append(tables, strings.Trim(m[1], "`\""))
+		}
+	}
+	return tables
+}
+
+// isDDL reports whether any statement in query changes the schema.
+func isDDL(query string) bool {
+	for _, stmt := range splitSQLStatements(query) {
+		keyword := strings.ToUpper(firstWords(stmt, 1))
+		for _, k := range ddlKeywords {
+			if keyword == k {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// splitSQLStatements splits SQL on semicolons outside quotes and drops
+// "--" line comments, returning the trimmed, non-empty statements.
+func splitSQLStatements(query string) []string {
+	var (
+		stmts []string
+		cur   strings.Builder
+		quote rune
+	)
+	flush := func() {
+		if s := strings.TrimSpace(cur.String()); s != "" {
+			stmts = append(stmts, s)
+		}
+		cur.Reset()
+	}
+	runes := []rune(query)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"' || r == '`':
+			quote = r
+		case r == '-' && i+1 < len(runes) && runes[i+1] == '-':
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+			cur.WriteRune(' ')
+			continue
+		case r == ';':
+			flush()
+			continue
+		}
+		cur.WriteRune(r)
+	}
+	flush()
+	return stmts
+}
+
+func firstWords(s string, n int) string {
+	fields := strings.Fields(s)
+	if len(fields) > n {
+		fields = fields[:n]
+	}
+	return strings.Join(fields, " ")
+}
+
+// AppliedMigration is a plugin migration recorded as applied.
+type AppliedMigration struct {
+	Version     int       `json:"version"`
+	Description string    `json:"description,omitempty"`
+	AppliedAt   time.Time `json:"applied_at"`
+}
+
+// SchemaStatus describes the state of a plugin's schema namespace.
+type SchemaStatus struct {
+	Plugin         string             `json:"plugin"`
+	Namespace      string             `json:"namespace"`
+	CurrentVersion int                `json:"current_version"`
+	LatestVersion  int                `json:"latest_version"`
+	Applied        []AppliedMigration `json:"applied"`
+}
+
+// SchemaMigrator applies plugin-declared migrations and tracks them in the
+// plugin_schema_migration table.
+type SchemaMigrator struct {
+	db     *sql.DB
+	logger *slog.Logger
+}
+
+// NewSchemaMigrator creates a migrator for the given database.
+func NewSchemaMigrator(db *sql.DB, logger *slog.Logger) *SchemaMigrator {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &SchemaMigrator{db: db, logger: logger}
+}
+
+// Applied returns the migrations recorded for a plugin, oldest first.
+func (m *SchemaMigrator) Applied(ctx context.Context, pluginName string) ([]AppliedMigration, error) {
+	rows, err := m.db.QueryContext(ctx, database.ConvertPlaceholders(`
+		SELECT version, description, applied_at FROM plugin_schema_migration
+		WHERE plugin_name = ? ORDER BY version`), pluginName)
+	if err != nil {
+		return nil, fmt.Errorf("list applied migrations: %w", err)
+	}
+	defer rows.Close()
+
+	var applied []AppliedMigration
+	for rows.Next() {
+		var a AppliedMigration
+		var desc sql.NullString
+		if err := rows.Scan(&a.Version, &desc, &a.AppliedAt); err != nil {
+			return nil, fmt.Errorf("scan applied migration: %w", err)
+		}
+		a.Description = desc.String
+		applied = append(applied, a)
+	}
+	return applied, rows.Err()
+}
+
+// CurrentVersion returns the highest migration version applied for a plugin,
+// or 0 if none.
+func (m *SchemaMigrator) CurrentVersion(ctx context.Context, pluginName string) (int, error) {
+	var version sql.NullInt64
+	err := m.db.QueryRowContext(ctx, database.ConvertPlaceholders(
+		`SELECT MAX(version) FROM plugin_schema_migration WHERE plugin_name = ?`), pluginName).Scan(&version)
+	if err != nil {
+		return 0, fmt.Errorf("get schema version: %w", err)
+	}
+	return int(version.Int64), nil
+}
+
+// Status reports the applied and latest migration versions for a plugin.
+func (m *SchemaMigrator) Status(ctx context.Context, manifest GKRegistration) (*SchemaStatus, error) {
+	applied, err := m.Applied(ctx, manifest.Name)
+	if err != nil {
+		return nil, err
+	}
+	status := &SchemaStatus{
+		Plugin:    manifest.Name,
+		Namespace: SchemaPrefix(manifest.Name),
+		Applied:   applied,
+	}
+	if n := len(applied); n > 0 {
+		status.CurrentVersion = applied[n-1].Version
+	}
+	if n := len(manifest.Migrations); n > 0 {
+		status.LatestVersion = manifest.Migrations[n-1].Version
+	}
+	return status, nil
+}
+
+// Migrate applies the manifest's pending migrations, each in its own
+// transaction, and returns how many were applied. It is used for both
+// install and upgrade; a schema newer than the manifest is an error.
+func (m *SchemaMigrator) Migrate(ctx context.Context, manifest GKRegistration) (int, error) {
+	if err := ValidateMigrations(manifest.Name, manifest.Migrations); err != nil {
+		return 0, err
+	}
+	if len(manifest.Migrations) == 0 {
+		return 0, nil
+	}
+
+	current, err := m.CurrentVersion(ctx, manifest.Name)
+	if err != nil {
+		return 0, err
+	}
+	latest := manifest.Migrations[len(manifest.Migrations)-1].Version
+	if current > latest {
+		return 0, fmt.Errorf("%w: installed %d, latest %d", ErrSchemaNewer, current, latest)
+	}
+
+	applied := 0
+	for _, mig := range manifest.Migrations {
+		if mig.Version <= current {
+			continue
+		}
+		if err := m.apply(ctx, manifest.Name, mig); err != nil {
+			return applied, fmt.Errorf("migration %d: %w", mig.Version, err)
+		}
+		applied++
+		m.logger.Info("plugin migration applied", "plugin", manifest.Name, "version", mig.Version)
+	}
+	return applied, nil
+}
+
+func (m *SchemaMigrator) apply(ctx context.Context, pluginName string, mig MigrationSpec) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for _, stmt := range splitSQLStatements(ExpandSchemaPrefix(mig.Up, pluginName)) {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	_, err = tx.ExecContext(ctx, database.ConvertPlaceholders(`
+		INSERT INTO plugin_schema_migration (plugin_name, version, description, applied_at)
+		VALUES (?, ?, ?, ?)`), pluginName, mig.Version, mig.Description, time.Now())
+	if err != nil {
+		return fmt.Errorf("record migration: %w", err)
+	}
+	return tx.Commit()
+}
+
+// Uninstall removes a plugin's schema. With dropData, applied migrations are
+// reverted newest first (Down SQL, or dropping the tables Up created) and
+// their records deleted. Without it, tables and records are kept so a later
+// reinstall resumes from the recorded version.
+func (m *SchemaMigrator) Uninstall(ctx context.Context, manifest GKRegistration, dropData bool) error {
+	if !dropData {
+		m.logger.Info("plugin uninstalled, schema retained", "plugin", manifest.Name)
+		return nil
+	}
+
+	applied, err := m.Applied(ctx, manifest.Name)
+	if err != nil {
+		return err
+	}
+	specs := make(map[int]MigrationSpec, len(manifest.Migrations))
+	for _, mig := range manifest.Migrations {
+		specs[mig.Version] = mig
+	}
+	sort.Slice(applied, func(i, j int) bool { return applied[i].Version > applied[j].Version })
+
+	for _, a := range applied {
+		mig, ok := specs[a.Version]
+		if !ok {
+			return fmt.Errorf("migration %d is applied but missing from the manifest", a.Version)
+		}
+		if err := m.revert(ctx, manifest.Name, mig); err != nil {
+			return fmt.Errorf("revert migration %d: %w", a.Version, err)
+		}
+	}
+
+	m.logger.Info("plugin uninstalled, schema dropped", "plugin", manifest.Name, "migrations", len(applied))
+	return nil
+}
+
+func (m *SchemaMigrator) revert(ctx context.Context, pluginName string, mig MigrationSpec) error {
+	var stmts []string
+	if strings.TrimSpace(mig.Down) != "" {
+		stmts = splitSQLStatements(ExpandSchemaPrefix(mig.Down, pluginName))
+	} else {
+		tables := createdTables(ExpandSchemaPrefix(mig.Up, pluginName))
+		for i := len(tables) - 1; i >= 0; i-- {
+			stmts = append(stmts, "DROP TABLE IF EXISTS "+tables[i])
+		}
+	}
+
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for _, stmt := range stmts {
+		if _, err := tx.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	_, err = tx.ExecContext(ctx, database.ConvertPlaceholders(
+		`DELETE FROM plugin_schema_migration WHERE plugin_name = ? AND version = ?`), pluginName, mig.Version)
+	if err != nil {
+		return fmt.Errorf("delete migration record: %w", err)
+	}
+	return tx.Commit()
+}
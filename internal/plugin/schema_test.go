@@ -0,0 +1,271 @@
+package plugin
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/goatkit/goatflow/internal/testutil"
+)
+
+// newSchemaTestDB returns a migrated database, which has the
+// plugin_schema_migration tracking table.
+func newSchemaTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	return testutil.MigratedDB(t)
+}
+
+func tableExists(t *testing.T, db *sql.DB, name string) bool {
+	t.Helper()
+	var n int
+	if err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?", name).Scan(&n); err != nil {
+		t.Fatalf("sqlite_master query failed: %v", err)
+	}
+	return n > 0
+}
+
+var statsMigrations = []MigrationSpec{
+	{
+		Version:     1,
+		Description: "counters",
+		Up: `-- per-queue counters
+			CREATE TABLE {{prefix}}counter (id INTEGER PRIMARY KEY, name TEXT);
+			CREATE INDEX {{prefix}}idx_counter_name ON {{prefix}}counter (name);
+			INSERT INTO {{prefix}}counter (name) VALUES ('a;b')`,
+	},
+	{
+		Version: 2,
+		Up:      "CREATE TABLE {{prefix}}snapshot (id INTEGER PRIMARY KEY)",
+		Down:    "DROP TABLE {{prefix}}snapshot",
+	},
+}
+
+func TestSchemaPrefix(t *testing.T) {
+	tests := map[string]string{
+		"stats":         "plg_stats_",
+		"My-Plugin":     "plg_my_plugin_",
+		"sla.reporting": "plg_sla_reporting_",
+	}
+	for name, want := range tests {
+		if got := SchemaPrefix(name); got != want {
+			t.Errorf("SchemaPrefix(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestValidateMigrations(t *testing.T) {
+	if err := ValidateMigrations("stats", statsMigrations); err != nil {
+		t.Fatalf("expected valid migrations, got %v", err)
+	}
+
+	tests := []struct {
+		name string
+		migs []MigrationSpec
+	}{
+		{"zero version", []MigrationSpec{{Version: 0, Up: "CREATE TABLE {{prefix}}a (id INT)"}}},
+		{"unordered", []MigrationSpec{
+			{Version: 2, Up: "CREATE TABLE {{prefix}}a (id INT)"},
+			{Version: 1, Up: "CREATE TABLE {{prefix}}b (id INT)"},
+		}},
+		{"empty up", []MigrationSpec{{Version: 1, Up: "  "}}},
+		{"host table", []MigrationSpec{{Version: 1, Up: "CREATE TABLE ticket_extra (id INT)"}}},
+		{"alter host table", []MigrationSpec{{Version: 1, Up: "ALTER TABLE ticket ADD COLUMN x INT"}}},
+		{"drop host table in list", []MigrationSpec{{Version: 1, Up: "DROP TABLE {{prefix}}a, ticket"}}},
+		{"rename out of namespace", []MigrationSpec{{Version: 1, Up: "ALTER TABLE {{prefix}}a RENAME TO ticket"}}},
+		{"schema qualified", []MigrationSpec{{Version: 1, Up: "CREATE TABLE public.plg_stats_a (id INT)"}}},
+		{"unprefixed index", []MigrationSpec{{Version: 1, Up: "CREATE INDEX idx_a ON {{prefix}}a (id)"}}},
+		{"delete host rows", []MigrationSpec{{Version: 1, Up: "DELETE FROM users"}}},
+		{"unsupported statement", []MigrationSpec{{Version: 1, Up: "GRANT ALL ON {{prefix}}a TO someone"}}},
+		{"bad down", []MigrationSpec{{Version: 1, Up: "CREATE TABLE {{prefix}}a (id INT)", Down: "DROP TABLE users"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateMigrations("stats", tt.migs); err == nil {
+				t.Error("expected validation error")
+			}
+		})
+	}
+}
+
+func TestSplitSQLStatements(t *testing.T) {
+	stmts := splitSQLStatements("-- comment; not a split\nINSERT INTO t VALUES ('x;y'); ;SELECT 1")
+	if len(stmts) != 2 {
+		t.Fatalf("expected 2 statements, got %d: %q", len(stmts), stmts)
+	}
+	if stmts[0] != "INSERT INTO t VALUES ('x;y')" {
+		t.Errorf("unexpected first statement %q", stmts[0])
+	}
+}
+
+func TestSchemaMigrator_InstallAndUpgrade(t *testing.T) {
+	db := newSchemaTestDB(t)
+	m := NewSchemaMigrator(db, nil)
+	ctx := context.Background()
+
+	v1 := GKRegistration{Name: "stats", Migrations: statsMigrations[:1]}
+	applied, err := m.Migrate(ctx, v1)
+	if err != nil {
+		t.Fatalf("install failed: %v", err)
+	}
+	if applied != 1 || !tableExists(t, db, "plg_stats_counter") {
+		t.Fatalf("expected counter table after install, applied %d", applied)
+	}
+
+	// Re-running the same manifest is a no-op
+	if applied, err := m.Migrate(ctx, v1); err != nil || applied != 0 {
+		t.Fatalf("expected no-op rerun, got %d (err %v)", applied, err)
+	}
+
+	v2 := GKRegistration{Name: "stats", Migrations: statsMigrations}
+	if applied, err := m.Migrate(ctx, v2); err != nil || applied != 1 {
+		t.Fatalf("expected one upgrade migration, got %d (err %v)", applied, err)
+	}
+	status, err := m.Status(ctx, v2)
+	if err != nil {
+		t.Fatalf("status failed: %v", err)
+	}
+	if status.CurrentVersion != 2 || status.LatestVersion != 2 || len(status.Applied) != 2 {
+		t.Errorf("unexpected status %+v", status)
+	}
+	if status.Applied[0].Description != "counters" || status.Namespace != "plg_stats_" {
+		t.Errorf("unexpected status details %+v", status)
+	}
+
+	// Downgrading below the installed schema is refused
+	if _, err := m.Migrate(ctx, v1); !errors.Is(err, ErrSchemaNewer) {
+		t.Errorf("expected ErrSchemaNewer, got %v", err)
+	}
+}
+
+func TestSchemaMigrator_FailedMigrationRollsBack(t *testing.T) {
+	db := newSchemaTestDB(t)
+	m := NewSchemaMigrator(db, nil)
+
+	manifest := GKRegistration{Name: "stats", Migrations: []MigrationSpec{{
+		Version: 1,
+		Up:      "CREATE TABLE {{prefix}}a (id INTEGER); INSERT INTO {{prefix}}missing (id) VALUES (1)",
+	}}}
+	if _, err := m.Migrate(context.Background(), manifest); err == nil {
+		t.Fatal("expected migration error")
+	}
+	if tableExists(t, db, "plg_stats_a") {
+		t.Error("expected partial migration to be rolled back")
+	}
+	if v, _ := m.CurrentVersion(context.Background(), "stats"); v != 0 {
+		t.Errorf("expected no recorded version, got %d", v)
+	}
+}
+
+func TestSchemaMigrator_Uninstall(t *testing.T) {
+	ctx := context.Background()
+	manifest := GKRegistration{Name: "stats", Migrations: statsMigrations}
+
+	t.Run("retain", func(t *testing.T) {
+		db := newSchemaTestDB(t)
+		m := NewSchemaMigrator(db, nil)
+		if _, err := m.Migrate(ctx, manifest); err != nil {
+			t.Fatalf("install failed: %v", err)
+		}
+		if err := m.Uninstall(ctx, manifest, false); err != nil {
+			t.Fatalf("uninstall failed: %v", err)
+		}
+		if !tableExists(t, db, "plg_stats_counter") {
+			t.Error("expected tables to be retained")
+		}
+		if v, _ := m.CurrentVersion(ctx, "stats"); v != 2 {
+			t.Errorf("expected version to be retained, got %d", v)
+		}
+	})
+
+	t.Run("drop", func(t *testing.T) {
+		db := newSchemaTestDB(t)
+		m := NewSchemaMigrator(db, nil)
+		if _, err := m.Migrate(ctx, manifest); err != nil {
+			t.Fatalf("install failed: %v", err)
+		}
+		if err := m.Uninstall(ctx, manifest, true); err != nil {
+			t.Fatalf("uninstall failed: %v", err)
+		}
+		for _, table := range []string{"plg_stats_counter", "plg_stats_snapshot"} {
+			if tableExists(t, db, table) {
+				t.Errorf("expected %s to be dropped", table)
+			}
+		}
+		if v, _ := m.CurrentVersion(ctx, "stats"); v != 0 {
+			t.Errorf("expected migration records to be removed, got version %d", v)
+		}
+	})
+}
+
+type schemaPlugin struct {
+	manifest GKRegistration
+}
+
+func (p *schemaPlugin) GKRegister() GKRegistration                   { return p.manifest }
+func (p *schemaPlugin) Init(ctx context.Context, host HostAPI) error { return nil }
+func (p *schemaPlugin) Call(ctx context.Context, fn string, args json.RawMessage) (json.RawMessage, error) {
+	return nil, nil
+}
+func (p *schemaPlugin) Shutdown(ctx context.Context) error { return nil }
+
+func TestManager_RegisterRunsMigrations(t *testing.T) {
+	db := newSchemaTestDB(t)
+	ctx := context.Background()
+	p := &schemaPlugin{manifest: GKRegistration{Name: "stats", Version: "1.0.0", Migrations: statsMigrations}}
+
+	if err := NewManager(nil).Register(ctx, p); err == nil {
+		t.Error("expected error registering a plugin with migrations and no migrator")
+	}
+
+	mgr := NewManager(nil)
+	mgr.SetSchemaMigrator(NewSchemaMigrator(db, nil))
+	if err := mgr.Register(ctx, p); err != nil {
+		t.Fatalf("register failed: %v", err)
+	}
+	if !tableExists(t, db, "plg_stats_snapshot") {
+		t.Error("expected migrations to run on register")
+	}
+
+	status, err := mgr.SchemaStatus(ctx, "stats")
+	if err != nil || status.CurrentVersion != 2 {
+		t.Fatalf("unexpected schema status %+v (err %v)", status, err)
+	}
+
+	if err := mgr.Uninstall(ctx, "stats", true); err != nil {
+		t.Fatalf("uninstall failed: %v", err)
+	}
+	if _, ok := mgr.Get("stats"); ok {
+		t.Error("expected plugin to be unregistered")
+	}
+	if tableExists(t, db, "plg_stats_counter") {
+		t.Error("expected plugin tables to be dropped")
+	}
+}
+
+func TestProdHostAPI_RejectsPluginDDL(t *testing.T) {
+	h := newTxTestHost(t)
+	pluginCtx := context.WithValue(context.Background(), PluginCallerKey, "stats")
+
+	for _, q := range []string{
+		"CREATE TABLE plg_stats_x (id INTEGER)",
+		"drop table items",
+		"INSERT INTO items (name) VALUES ('a'); ALTER TABLE items ADD COLUMN x INT",
+	} {
+		if _, err := h.DBExec(pluginCtx, q); !errors.Is(err, ErrDDLNotAllowed) {
+			t.Errorf("DBExec(%q): expected ErrDDLNotAllowed, got %v", q, err)
+		}
+		if _, err := h.DBQuery(pluginCtx, q); !errors.Is(err, ErrDDLNotAllowed) {
+			t.Errorf("DBQuery(%q): expected ErrDDLNotAllowed, got %v", q, err)
+		}
+	}
+
+	if _, err := h.DBExec(pluginCtx, "INSERT INTO items (name) VALUES ('created')"); err != nil {
+		t.Errorf("expected DML to be allowed, got %v", err)
+	}
+	// Host-internal calls carry no plugin caller and are not restricted
+	if _, err := h.DBExec(context.Background(), "CREATE TABLE host_only (id INTEGER)"); err != nil {
+		t.Errorf("expected host DDL to be allowed, got %v", err)
+	}
+}
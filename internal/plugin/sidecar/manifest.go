@@ -0,0 +1,101 @@
+// Package sidecar runs plugins written in any language as child processes.
+//
+// A sidecar plugin is a directory with a plugin.yaml declaring
+// `runtime: exec` and the command that starts it. Host and plugin exchange
+// JSON-RPC 2.0 messages, one per line, over the plugin's stdin and stdout:
+//
+//   - The host calls gk_register, gk_init, gk_call and gk_shutdown, mirroring
+//     the exports of WASM plugins.
+//   - While it handles gk_call the plugin may call host functions by their
+//     WASM names (db_query, http_request, ...) with the same JSON arguments,
+//     and send "log" notifications.
+//
+// Anything the plugin writes to stderr ends up in the host log.
+package sidecar
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ManifestFile is the file that marks a directory as a sidecar plugin.
+const ManifestFile = "plugin.yaml"
+
+// RuntimeExec is the only runtime a plugin.yaml may declare.
+const RuntimeExec = "exec"
+
+// DefaultCallTimeout bounds a gk_call when plugin.yaml sets no timeout.
+const DefaultCallTimeout = 30 * time.Second
+
+// Manifest is the content of plugin.yaml. The plugin's registration still
+// comes from its gk_register answer; Name only names the plugin before it
+// has started.
+type Manifest struct {
+	Name    string            `yaml:"name"`
+	Runtime string            `yaml:"runtime"`
+	Command []string          `yaml:"command"`
+	Env     map[string]string `yaml:"env"`
+	Timeout string            `yaml:"timeout"`
+
+	// Dir is the directory holding plugin.yaml; the command runs there.
+	Dir string `yaml:"-"`
+}
+
+// LoadManifest reads and checks a plugin.yaml.
+func LoadManifest(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var m Manifest
+	if err := yaml.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	m.Dir = filepath.Dir(path)
+	if m.Name == "" {
+		m.Name = filepath.Base(m.Dir)
+	}
+	if err := m.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &m, nil
+}
+
+// Validate checks the runtime, command and timeout.
+func (m *Manifest) Validate() error {
+	if m.Runtime != RuntimeExec {
+		return fmt.Errorf("unsupported runtime %q (want %q)", m.Runtime, RuntimeExec)
+	}
+	if len(m.Command) == 0 || strings.TrimSpace(m.Command[0]) == "" {
+		return fmt.Errorf("command is required")
+	}
+	if m.Timeout != "" {
+		if d, err := time.ParseDuration(m.Timeout); err != nil || d <= 0 {
+			return fmt.Errorf("invalid timeout %q", m.Timeout)
+		}
+	}
+	return nil
+}
+
+// CallTimeout returns the configured gk_call timeout.
+func (m *Manifest) CallTimeout() time.Duration {
+	if d, err := time.ParseDuration(m.Timeout); err == nil && d > 0 {
+		return d
+	}
+	return DefaultCallTimeout
+}
+
+// commandPath resolves a relative program path such as ./main.py against
+// the plugin directory; bare names are looked up in PATH.
+func (m *Manifest) commandPath() string {
+	prog := m.Command[0]
+	if !filepath.IsAbs(prog) && strings.ContainsRune(prog, filepath.Separator) {
+		return filepath.Join(m.Dir, prog)
+	}
+	return prog
+}
@@ -0,0 +1,327 @@
+package sidecar
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/goatkit/goatflow/internal/plugin"
+	grpcplugin "github.com/goatkit/goatflow/internal/plugin/grpc"
+)
+
+// JSON-RPC error codes sent to plugins.
+const (
+	codeMethodNotFound = -32601
+	codeHostError      = -32000
+)
+
+// message is a JSON-RPC 2.0 request, notification or response.
+type message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      *int64          `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    any    `json:"data,omitempty"`
+}
+
+// Plugin is a running sidecar process implementing plugin.Plugin.
+type Plugin struct {
+	name         string
+	registration plugin.GKRegistration
+	callTimeout  time.Duration
+
+	cmd     *exec.Cmd
+	stdin   io.WriteCloser
+	writeMu sync.Mutex
+	msgs    chan message
+	exited  chan struct{}
+
+	callMu sync.Mutex // one request in flight; host calls arrive during it
+	nextID int64
+
+	hostMu sync.RWMutex
+	host   plugin.HostAPI
+}
+
+var _ plugin.Plugin = (*Plugin)(nil)
+
+// Load starts the plugin declared by a plugin.yaml and asks it for its
+// registration.
+func Load(ctx context.Context, manifestPath string) (*Plugin, error) {
+	m, err := LoadManifest(manifestPath)
+	if err != nil {
+		return nil, err
+	}
+	return Start(ctx, m)
+}
+
+// Start runs the manifest's command and asks the plugin for its
+// registration.
+func Start(ctx context.Context, m *Manifest) (*Plugin, error) {
+	cmd := exec.Command(m.commandPath(), m.Command[1:]...)
+	cmd.Dir = m.Dir
+	cmd.Env = os.Environ()
+	for k, v := range m.Env {
+		cmd.Env = append(cmd.Env, k+"="+v)
+	}
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("start %s: %w", m.Command[0], err)
+	}
+
+	p := &Plugin{
+		name:        m.Name,
+		callTimeout: m.CallTimeout(),
+		cmd:         cmd,
+		stdin:       stdin,
+		msgs:        make(chan message, 64),
+		exited:      make(chan struct{}),
+	}
+	go p.readStdout(stdout)
+	go p.readStderr(stderr)
+
+	regCtx, cancel := context.WithTimeout(ctx, p.callTimeout)
+	defer cancel()
+	raw, err := p.request(regCtx, "gk_register", nil)
+	if err == nil {
+		err = json.Unmarshal(raw, &p.registration)
+	}
+	if err == nil && p.registration.Name == "" {
+		err = errors.New("registration has no name")
+	}
+	if err != nil {
+		p.kill()
+		return nil, fmt.Errorf("gk_register failed: %w", err)
+	}
+	p.name = p.registration.Name
+	return p, nil
+}
+
+// readStdout decodes messages from the plugin. Log notifications are
+// handled here so they need no request in flight.
+func (p *Plugin) readStdout(r io.Reader) {
+	defer close(p.exited)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var msg message
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			p.logLine("warn", "invalid message from plugin: "+scanner.Text())
+			continue
+		}
+		if msg.Method == "log" && msg.ID == nil {
+			p.handleLog(msg.Params)
+			continue
+		}
+		select {
+		case p.msgs <- msg:
+		default:
+			p.logLine("warn", "dropped message from plugin while no request was in flight")
+		}
+	}
+}
+
+func (p *Plugin) readStderr(r io.Reader) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		p.logLine("info", scanner.Text())
+	}
+}
+
+func (p *Plugin) handleLog(params json.RawMessage) {
+	var entry struct {
+		Level   string         `json:"level"`
+		Message string         `json:"message"`
+		Fields  map[string]any `json:"fields"`
+	}
+	if err := json.Unmarshal(params, &entry); err != nil {
+		return
+	}
+	if entry.Level == "" {
+		entry.Level = "info"
+	}
+	if host := p.hostAPI(); host != nil {
+		if entry.Fields == nil {
+			entry.Fields = map[string]any{}
+		}
+		entry.Fields["plugin"] = p.name
+		host.Log(context.Background(), entry.Level, entry.Message, entry.Fields)
+		return
+	}
+	log.Printf("[plugin:%s] %s %s", p.name, entry.Level, entry.Message)
+}
+
+func (p *Plugin) logLine(level, line string) {
+	if host := p.hostAPI(); host != nil {
+		host.Log(context.Background(), level, line, map[string]any{"plugin": p.name, "stream": "stderr"})
+		return
+	}
+	log.Printf("[plugin:%s] %s", p.name, line)
+}
+
+func (p *Plugin) hostAPI() plugin.HostAPI {
+	p.hostMu.RLock()
+	defer p.hostMu.RUnlock()
+	return p.host
+}
+
+func (p *Plugin) write(msg message) error {
+	msg.JSONRPC = "2.0"
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	p.writeMu.Lock()
+	defer p.writeMu.Unlock()
+	_, err = p.stdin.Write(append(data, '\n'))
+	return err
+}
+
+// request sends a request and waits for its response, serving the host
+// calls the plugin makes meanwhile.
+func (p *Plugin) request(ctx context.Context, method string, params any) (json.RawMessage, error) {
+	p.callMu.Lock()
+	defer p.callMu.Unlock()
+
+	raw, err := json.Marshal(params)
+	if err != nil {
+		return nil, err
+	}
+	p.nextID++
+	id := p.nextID
+	if err := p.write(message{ID: &id, Method: method, Params: raw}); err != nil {
+		return nil, fmt.Errorf("write %s: %w", method, err)
+	}
+	for {
+		select {
+		case msg := <-p.msgs:
+			switch {
+			case msg.Method != "":
+				p.serveHostCall(ctx, msg)
+			case msg.ID != nil && *msg.ID == id:
+				if msg.Error != nil {
+					return nil, errors.New(msg.Error.Message)
+				}
+				return msg.Result, nil
+			}
+			// Responses to requests that timed out earlier are dropped
+		case <-p.exited:
+			return nil, fmt.Errorf("plugin %s exited", p.name)
+		case <-ctx.Done():
+			return nil, fmt.Errorf("%s: %w", method, ctx.Err())
+		}
+	}
+}
+
+// serveHostCall answers a host function call from the plugin, with the
+// semantics gRPC plugins get.
+func (p *Plugin) serveHostCall(ctx context.Context, msg message) {
+	host := p.hostAPI()
+	var result json.RawMessage
+	var err error
+	if host == nil {
+		err = errors.New("host API not available before gk_init")
+	} else {
+		ctx = context.WithValue(ctx, plugin.PluginCallerKey, p.name)
+		result, err = grpcplugin.DispatchHostCall(ctx, host, msg.Method, msg.Params)
+	}
+	if msg.ID == nil {
+		return
+	}
+	resp := message{ID: msg.ID, Result: result}
+	if err != nil {
+		resp.Result = nil
+		resp.Error = &rpcError{Code: codeHostError, Message: err.Error()}
+		var unknown *grpcplugin.UnknownMethodError
+		if errors.As(err, &unknown) {
+			resp.Error.Code = codeMethodNotFound
+		}
+		if code := grpcplugin.HostErrorCode(err); code != "" {
+			resp.Error.Data = map[string]string{"code": code}
+		}
+	}
+	if resp.Error == nil && resp.Result == nil {
+		resp.Result = json.RawMessage("null")
+	}
+	if werr := p.write(resp); werr != nil {
+		p.logLine("error", "write host call result: "+werr.Error())
+	}
+}
+
+// GKRegister implements plugin.Plugin.
+func (p *Plugin) GKRegister() plugin.GKRegistration {
+	return p.registration
+}
+
+// Init implements plugin.Plugin.
+func (p *Plugin) Init(ctx context.Context, host plugin.HostAPI) error {
+	p.hostMu.Lock()
+	p.host = host
+	p.hostMu.Unlock()
+	ctx, cancel := context.WithTimeout(ctx, p.callTimeout)
+	defer cancel()
+	_, err := p.request(ctx, "gk_init", map[string]any{
+		"config": map[string]string{"host_version": "0.7.0"},
+	})
+	return err
+}
+
+// Call implements plugin.Plugin.
+func (p *Plugin) Call(ctx context.Context, fn string, args json.RawMessage) (json.RawMessage, error) {
+	if len(args) == 0 {
+		args = json.RawMessage("{}")
+	}
+	ctx, cancel := context.WithTimeout(ctx, p.callTimeout)
+	defer cancel()
+	return p.request(ctx, "gk_call", map[string]any{"fn": fn, "args": args})
+}
+
+// Shutdown implements plugin.Plugin. The plugin gets a few seconds to answer
+// gk_shutdown and exit before it is killed.
+func (p *Plugin) Shutdown(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	_, err := p.request(ctx, "gk_shutdown", nil)
+	_ = p.stdin.Close()
+	select {
+	case <-p.exited:
+		_ = p.cmd.Wait()
+	case <-ctx.Done():
+		p.kill()
+	}
+	return err
+}
+
+func (p *Plugin) kill() {
+	_ = p.stdin.Close()
+	if p.cmd.Process != nil {
+		_ = p.cmd.Process.Kill()
+	}
+	_ = p.cmd.Wait()
+}
@@ -0,0 +1,199 @@
+package sidecar
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/plugin/plugintest"
+)
+
+const helperEnv = "GK_SIDECAR_HELPER"
+
+// TestHelperPlugin is not a real test: when started by startHelper it turns
+// the test binary into a sidecar plugin speaking JSON-RPC on stdio.
+func TestHelperPlugin(t *testing.T) {
+	if os.Getenv(helperEnv) != "1" {
+		t.Skip("helper process")
+	}
+	in := bufio.NewScanner(os.Stdin)
+	out := json.NewEncoder(os.Stdout)
+	nextID := int64(100)
+
+	hostCall := func(method string, params any) message {
+		raw, _ := json.Marshal(params)
+		nextID++
+		id := nextID
+		_ = out.Encode(message{JSONRPC: "2.0", ID: &id, Method: method, Params: raw})
+		for in.Scan() {
+			var msg message
+			if json.Unmarshal(in.Bytes(), &msg) == nil && msg.ID != nil && *msg.ID == id {
+				return msg
+			}
+		}
+		os.Exit(0)
+		return message{}
+	}
+	reply := func(id *int64, result any, errMsg string) {
+		msg := message{JSONRPC: "2.0", ID: id}
+		if errMsg != "" {
+			msg.Error = &rpcError{Code: -32000, Message: errMsg}
+		} else {
+			msg.Result, _ = json.Marshal(result)
+		}
+		_ = out.Encode(msg)
+	}
+
+	for in.Scan() {
+		var req message
+		if err := json.Unmarshal(in.Bytes(), &req); err != nil {
+			continue
+		}
+		switch req.Method {
+		case "gk_register":
+			reply(req.ID, map[string]any{"name": "helper", "version": "1.0.0"}, "")
+		case "gk_init":
+			fmt.Fprintln(os.Stderr, "helper starting")
+			reply(req.ID, nil, "")
+		case "gk_shutdown":
+			reply(req.ID, nil, "")
+			os.Exit(0)
+		case "gk_call":
+			var call struct {
+				Fn   string          `json:"fn"`
+				Args json.RawMessage `json:"args"`
+			}
+			_ = json.Unmarshal(req.Params, &call)
+			switch call.Fn {
+			case "tickets":
+				logParams, _ := json.Marshal(map[string]string{"level": "debug", "message": "listing tickets"})
+				_ = out.Encode(message{JSONRPC: "2.0", Method: "log", Params: logParams})
+				resp := hostCall("db_query", map[string]any{"query": "SELECT id FROM ticket", "args": []any{}})
+				reply(req.ID, map[string]any{"rows": resp.Result}, "")
+			case "bogus":
+				resp := hostCall("bogus_call", map[string]any{})
+				reply(req.ID, resp.Error, "")
+			case "fail":
+				reply(req.ID, nil, "boom")
+			case "slow":
+				time.Sleep(450 * time.Millisecond)
+				reply(req.ID, nil, "")
+			default:
+				reply(req.ID, map[string]any{"echo": call.Fn, "args": call.Args}, "")
+			}
+		}
+	}
+	os.Exit(0)
+}
+
+func startHelper(t *testing.T, timeout string) *Plugin {
+	t.Helper()
+	p, err := Start(context.Background(), &Manifest{
+		Name:    "helper",
+		Runtime: RuntimeExec,
+		Command: []string{os.Args[0], "-test.run=^TestHelperPlugin$"},
+		Env:     map[string]string{helperEnv: "1"},
+		Timeout: timeout,
+		Dir:     t.TempDir(),
+	})
+	require.NoError(t, err)
+	return p
+}
+
+func TestSidecarPlugin(t *testing.T) {
+	p := startHelper(t, "")
+	assert.Equal(t, "helper", p.GKRegister().Name)
+
+	host := plugintest.NewMockHost(plugintest.HostScript{
+		Queries: []plugintest.QueryStub{{Match: "FROM ticket", Rows: []map[string]any{{"id": 7}}}},
+	})
+	ctx := context.Background()
+	require.NoError(t, p.Init(ctx, host))
+
+	result, err := p.Call(ctx, "tickets", nil)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"rows": [{"id": 7}]}`, string(result))
+
+	result, err = p.Call(ctx, "echo", json.RawMessage(`{"a": 1}`))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"echo": "echo", "args": {"a": 1}}`, string(result))
+
+	result, err = p.Call(ctx, "bogus", nil)
+	require.NoError(t, err)
+	assert.Contains(t, string(result), fmt.Sprint(codeMethodNotFound))
+
+	_, err = p.Call(ctx, "fail", nil)
+	assert.EqualError(t, err, "boom")
+
+	var sawLog bool
+	for _, call := range host.Calls() {
+		if call["fn"] == "log" && call["message"] == "listing tickets" {
+			sawLog = true
+			assert.Equal(t, "debug", call["level"])
+		}
+	}
+	assert.True(t, sawLog, "log notification reaches the host")
+	require.Eventually(t, func() bool {
+		for _, call := range host.Calls() {
+			if call["fn"] == "log" && call["message"] == "helper starting" {
+				return true
+			}
+		}
+		return false
+	}, time.Second, 10*time.Millisecond, "stderr reaches the host log")
+
+	require.NoError(t, p.Shutdown(ctx))
+	_, err = p.Call(ctx, "echo", nil)
+	assert.Error(t, err, "calls fail once the plugin has exited")
+}
+
+func TestSidecarCallTimeout(t *testing.T) {
+	p := startHelper(t, "300ms")
+	ctx := context.Background()
+	require.NoError(t, p.Init(ctx, plugintest.NewMockHost(plugintest.HostScript{})))
+
+	start := time.Now()
+	_, err := p.Call(ctx, "slow", nil)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), 450*time.Millisecond)
+
+	// The plugin answers the timed out call late; that answer is dropped
+	result, err := p.Call(ctx, "echo", nil)
+	require.NoError(t, err)
+	assert.Contains(t, string(result), `"echo"`)
+	require.NoError(t, p.Shutdown(ctx))
+}
+
+func TestLoadManifest(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "greeter")
+	require.NoError(t, os.MkdirAll(dir, 0o755))
+	path := filepath.Join(dir, ManifestFile)
+
+	require.NoError(t, os.WriteFile(path, []byte("runtime: exec\ncommand: [python3, ./main.py]\ntimeout: 5s\n"), 0o644))
+	m, err := LoadManifest(path)
+	require.NoError(t, err)
+	assert.Equal(t, "greeter", m.Name)
+	assert.Equal(t, 5*time.Second, m.CallTimeout())
+	assert.Equal(t, "python3", m.commandPath())
+
+	m.Command = []string{"./plugin"}
+	assert.Equal(t, filepath.Join(dir, "plugin"), m.commandPath())
+
+	for _, bad := range []string{
+		"runtime: wasm\ncommand: [node]\n",
+		"runtime: exec\n",
+		"runtime: exec\ncommand: [node]\ntimeout: soon\n",
+	} {
+		require.NoError(t, os.WriteFile(path, []byte(bad), 0o644))
+		_, err := LoadManifest(path)
+		assert.Error(t, err, bad)
+	}
+}
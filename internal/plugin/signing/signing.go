@@ -0,0 +1,198 @@
+// Package signing signs plugin artifacts with ed25519 keys and verifies them
+// against trusted public keys. A signature is kept next to its artifact in a
+// detached file, see DefaultSignaturePath.
+package signing
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// SignatureExt is appended to an artifact's path to name its signature.
+const SignatureExt = ".sig"
+
+// Errors returned by Verify and VerifyFile.
+var (
+	ErrNoSignature  = errors.New("signing: artifact is not signed")
+	ErrUntrusted    = errors.New("signing: signature does not match any trusted key")
+	ErrNoTrustedKey = errors.New("signing: no trusted keys")
+)
+
+// DefaultSignaturePath returns where the signature of an artifact is kept:
+// plugin.wasm is signed in plugin.wasm.sig.
+func DefaultSignaturePath(artifact string) string {
+	return artifact + SignatureExt
+}
+
+// GenerateKey creates a new key pair.
+func GenerateKey() (ed25519.PublicKey, ed25519.PrivateKey, error) {
+	return ed25519.GenerateKey(rand.Reader)
+}
+
+// KeyID is a short fingerprint of a public key, used to name keys in
+// output.
+func KeyID(pub ed25519.PublicKey) string {
+	sum := sha256.Sum256(pub)
+	return hex.EncodeToString(sum[:8])
+}
+
+// EncodePrivateKey encodes a private key as PKCS #8 PEM, as OpenSSL writes it.
+func EncodePrivateKey(priv ed25519.PrivateKey) ([]byte, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+// EncodePublicKey encodes a public key as PKIX PEM.
+func EncodePublicKey(pub ed25519.PublicKey) ([]byte, error) {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), nil
+}
+
+// ParsePrivateKey parses a PEM private key written by EncodePrivateKey.
+func ParsePrivateKey(data []byte) (ed25519.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "PRIVATE KEY" {
+		return nil, errors.New("signing: not a PEM private key")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("signing: %w", err)
+	}
+	priv, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, errors.New("signing: not an ed25519 private key")
+	}
+	return priv, nil
+}
+
+// ParsePublicKey parses a PEM public key written by EncodePublicKey.
+func ParsePublicKey(data []byte) (ed25519.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "PUBLIC KEY" {
+		return nil, errors.New("signing: not a PEM public key")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("signing: %w", err)
+	}
+	pub, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, errors.New("signing: not an ed25519 public key")
+	}
+	return pub, nil
+}
+
+// Sign returns the detached signature of an artifact: the base64 ed25519
+// signature of its bytes on one line.
+func Sign(priv ed25519.PrivateKey, artifact []byte) []byte {
+	sig := ed25519.Sign(priv, artifact)
+	return []byte(base64.StdEncoding.EncodeToString(sig) + "\n")
+}
+
+// Verify checks a detached signature against the trusted keys and returns
+// the key that made it.
+func Verify(artifact, signature []byte, trusted []ed25519.PublicKey) (ed25519.PublicKey, error) {
+	if len(trusted) == 0 {
+		return nil, ErrNoTrustedKey
+	}
+	sig, err := base64.StdEncoding.DecodeString(string(bytes.TrimSpace(signature)))
+	if err != nil || len(sig) != ed25519.SignatureSize {
+		return nil, errors.New("signing: malformed signature")
+	}
+	for _, pub := range trusted {
+		if ed25519.Verify(pub, artifact, sig) {
+			return pub, nil
+		}
+	}
+	return nil, ErrUntrusted
+}
+
+// SignFile signs an artifact and writes the signature to its
+// DefaultSignaturePath, returning that path.
+func SignFile(priv ed25519.PrivateKey, artifact string) (string, error) {
+	data, err := os.ReadFile(artifact)
+	if err != nil {
+		return "", err
+	}
+	path := DefaultSignaturePath(artifact)
+	if err := os.WriteFile(path, Sign(priv, data), 0o644); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// VerifyFile verifies an artifact against the signature at its
+// DefaultSignaturePath.
+func VerifyFile(artifact string, trusted []ed25519.PublicKey) (ed25519.PublicKey, error) {
+	sig, err := os.ReadFile(DefaultSignaturePath(artifact))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNoSignature
+	}
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(artifact)
+	if err != nil {
+		return nil, err
+	}
+	return Verify(data, sig, trusted)
+}
+
+// LoadTrustedKeys reads the public keys in the given files and in the *.pub
+// files of the given directories.
+func LoadTrustedKeys(paths ...string) ([]ed25519.PublicKey, error) {
+	var files []string
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, p)
+			continue
+		}
+		matches, err := filepath.Glob(filepath.Join(p, "*.pub"))
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(matches)
+		files = append(files, matches...)
+	}
+	keys := make([]ed25519.PublicKey, 0, len(files))
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		pub, err := ParsePublicKey(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f, err)
+		}
+		keys = append(keys, pub)
+	}
+	return keys, nil
+}
+
+// IsSignature reports whether a path names a detached signature, so
+// directory scans can skip it.
+func IsSignature(path string) bool {
+	return strings.HasSuffix(path, SignatureExt)
+}
@@ -0,0 +1,59 @@
+package signing
+
+import (
+	"crypto/ed25519"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignAndVerifyFile(t *testing.T) {
+	dir := t.TempDir()
+	pub, priv, err := GenerateKey()
+	require.NoError(t, err)
+	otherPub, _, err := GenerateKey()
+	require.NoError(t, err)
+
+	// Keys survive a PEM round trip
+	privPEM, err := EncodePrivateKey(priv)
+	require.NoError(t, err)
+	priv, err = ParsePrivateKey(privPEM)
+	require.NoError(t, err)
+	pubPEM, err := EncodePublicKey(pub)
+	require.NoError(t, err)
+	keysDir := filepath.Join(dir, "trusted")
+	require.NoError(t, os.Mkdir(keysDir, 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(keysDir, "release.pub"), pubPEM, 0o644))
+	_, err = ParsePublicKey(privPEM)
+	assert.Error(t, err)
+
+	artifact := filepath.Join(dir, "hello.wasm")
+	require.NoError(t, os.WriteFile(artifact, []byte("\x00asm plugin"), 0o644))
+	_, err = VerifyFile(artifact, []ed25519.PublicKey{pub})
+	assert.ErrorIs(t, err, ErrNoSignature)
+
+	sigPath, err := SignFile(priv, artifact)
+	require.NoError(t, err)
+	assert.Equal(t, artifact+".sig", sigPath)
+	assert.True(t, IsSignature(sigPath))
+
+	trusted, err := LoadTrustedKeys(keysDir)
+	require.NoError(t, err)
+	require.Len(t, trusted, 1)
+	signer, err := VerifyFile(artifact, trusted)
+	require.NoError(t, err)
+	assert.Equal(t, KeyID(pub), KeyID(signer))
+
+	_, err = VerifyFile(artifact, []ed25519.PublicKey{otherPub})
+	assert.ErrorIs(t, err, ErrUntrusted)
+	_, err = VerifyFile(artifact, nil)
+	assert.ErrorIs(t, err, ErrNoTrustedKey)
+
+	// A changed artifact no longer verifies
+	require.NoError(t, os.WriteFile(artifact, []byte("\x00asm tampered"), 0o644))
+	_, err = VerifyFile(artifact, trusted)
+	assert.ErrorIs(t, err, ErrUntrusted)
+}
@@ -0,0 +1,38 @@
+package plugin
+
+// PluginStats reports runtime statistics of a plugin for the plugins API.
+// Fields a runtime does not track are nil.
+type PluginStats struct {
+	Pool *PoolStats `json:"pool,omitempty"` // WASM instance pool
+}
+
+// PoolStats describes a plugin's pool of module instances. Each instance
+// serves one call at a time, so concurrent calls beyond Max wait.
+type PoolStats struct {
+	Min       int    `json:"min"`       // Instances created at load
+	Max       int    `json:"max"`       // Upper bound on instances
+	Instances int    `json:"instances"` // Live instances
+	InUse     int    `json:"in_use"`    // Instances serving a call
+	Waiting   int    `json:"waiting"`   // Calls waiting for an instance
+	Created   uint64 `json:"created"`   // Instances created since load, including restarts
+}
+
+// StatsReporter is implemented by plugins whose runtime reports statistics.
+type StatsReporter interface {
+	Stats() PluginStats
+}
+
+// Stats returns the runtime statistics of a registered plugin. Plugins
+// whose runtime reports none return empty stats.
+func (m *Manager) Stats(name string) PluginStats {
+	m.mu.RLock()
+	rp, ok := m.plugins[name]
+	m.mu.RUnlock()
+	if !ok {
+		return PluginStats{}
+	}
+	if reporter, ok := rp.plugin.(StatsReporter); ok {
+		return reporter.Stats()
+	}
+	return PluginStats{}
+}
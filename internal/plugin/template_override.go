@@ -3,21 +3,49 @@ package plugin
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
 	"sync"
+
+	"github.com/flosch/pongo2/v6"
+)
+
+// maxTemplateExtendsDepth bounds how far up the {% extends %} chain a host
+// template's blocks are looked for.
+const maxTemplateExtendsDepth = 10
+
+var (
+	templateBlockPattern       = regexp.MustCompile(`\{%-?\s*block\s+(\w+)\s*-?%\}`)
+	templateExtendsPattern     = regexp.MustCompile(`\{%-?\s*extends\s`)
+	templateExtendsNamePattern = regexp.MustCompile(`\{%-?\s*extends\s+"([^"]+)"`)
 )
 
 // TemplateOverride stores information about a template override.
 type TemplateOverride struct {
-	PluginName   string // Plugin providing the override
-	TemplateName string // Original template name being overridden
-	Handler      string // Plugin function to call for template content
+	PluginName   string           // Plugin providing the override
+	TemplateName string           // Original template name being overridden
+	Handler      string           // Plugin function to call for template content
+	Blocks       []string         // Host template blocks replaced; empty for the whole template
+	Template     *pongo2.Template // Compiled source, rendered instead of calling Handler
 }
 
+// TemplateChangeListener is notified with the names of the templates whose
+// overrides a plugin added, replaced or removed.
+type TemplateChangeListener func(pluginName string, templates []string)
+
 // TemplateOverrideRegistry manages template overrides from plugins.
 type TemplateOverrideRegistry struct {
 	mu        sync.RWMutex
 	overrides map[string]*TemplateOverride // template name -> override
 	manager   *Manager
+	set       *pongo2.TemplateSet // resolves host templates extended by block overrides
+	dir       string              // host template directory
+	listener  TemplateChangeListener
 }
 
 // NewTemplateOverrideRegistry creates a new template override registry.
@@ -28,32 +56,193 @@ func NewTemplateOverrideRegistry(mgr *Manager) *TemplateOverrideRegistry {
 	}
 }
 
-// Register registers template overrides from a plugin.
-func (r *TemplateOverrideRegistry) Register(pluginName string, templates []TemplateSpec) {
+// SetTemplateDir points the registry at the host templates. Block overrides
+// extend them, and whole-template overrides may include them.
+func (r *TemplateOverrideRegistry) SetTemplateDir(dir string) error {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	loader, err := pongo2.NewLocalFileSystemLoader(abs)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.dir = abs
+	r.set = pongo2.NewSet("plugin-overrides", loader)
+	return nil
+}
+
+// SetChangeListener sets the callback invoked after a plugin's overrides
+// change, so pages rendered from those templates can be refreshed.
+func (r *TemplateOverrideRegistry) SetChangeListener(fn TemplateChangeListener) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
+	r.listener = fn
+}
+
+// Register registers template overrides from a plugin, replacing those it
+// registered before. Templates with a source are compiled here: ones that
+// fail to parse, or replace blocks the host template does not define, are
+// rejected and reported in the returned error while the rest still register.
+func (r *TemplateOverrideRegistry) Register(pluginName string, templates []TemplateSpec) error {
+	r.mu.Lock()
+	changed := r.removeLocked(pluginName)
 
+	var errs []error
 	for _, t := range templates {
-		if t.Override {
-			r.overrides[t.Name] = &TemplateOverride{
-				PluginName:   pluginName,
-				TemplateName: t.Name,
-				Handler:      "template_" + sanitizeTemplateName(t.Name),
+		if !t.Override {
+			continue
+		}
+		override := &TemplateOverride{
+			PluginName:   pluginName,
+			TemplateName: t.Name,
+			Handler:      "template_" + sanitizeTemplateName(t.Name),
+			Blocks:       t.Blocks,
+		}
+		if t.Source != "" || len(t.Blocks) > 0 {
+			tmpl, err := r.compileLocked(t)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("template %q rejected: %w", t.Name, err))
+				continue
 			}
+			override.Template = tmpl
 		}
+		r.overrides[t.Name] = override
+		changed = appendTemplateName(changed, t.Name)
 	}
+	listener := r.listener
+	r.mu.Unlock()
+
+	notifyTemplateChange(listener, pluginName, changed)
+	return errors.Join(errs...)
+}
+
+// compileLocked parses an override's source. Block overrides are compiled as
+// a child of the host template so only the listed blocks change.
+func (r *TemplateOverrideRegistry) compileLocked(t TemplateSpec) (*pongo2.Template, error) {
+	if t.Source == "" {
+		return nil, errors.New("blocks require a source")
+	}
+	if len(t.Blocks) == 0 {
+		if r.set != nil {
+			return r.set.FromString(t.Source)
+		}
+		return pongo2.FromString(t.Source)
+	}
+
+	if err := validateTemplateName(t.Name); err != nil {
+		return nil, err
+	}
+	if r.set == nil {
+		return nil, errors.New("block overrides need the host template directory")
+	}
+	if templateExtendsPattern.MatchString(t.Source) {
+		return nil, errors.New("block overrides must not use {% extends %}")
+	}
+
+	allowed := make(map[string]bool, len(t.Blocks))
+	for _, b := range t.Blocks {
+		allowed[b] = true
+	}
+	for _, m := range templateBlockPattern.FindAllStringSubmatch(t.Source, -1) {
+		if !allowed[m[1]] {
+			return nil, fmt.Errorf("block %q is not listed in blocks", m[1])
+		}
+	}
+
+	hostBlocks, err := r.hostBlocksLocked(t.Name, 0)
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range t.Blocks {
+		if !hostBlocks[b] {
+			return nil, fmt.Errorf("host template has no block %q", b)
+		}
+	}
+
+	return r.set.FromString(`{% extends "` + t.Name + `" %}` + t.Source)
+}
+
+// hostBlocksLocked returns the blocks a host template and the templates it
+// extends define.
+func (r *TemplateOverrideRegistry) hostBlocksLocked(name string, depth int) (map[string]bool, error) {
+	if depth > maxTemplateExtendsDepth {
+		return nil, fmt.Errorf("host template %q extends too deeply", name)
+	}
+	if err := validateTemplateName(name); err != nil {
+		return nil, err
+	}
+	src, err := os.ReadFile(filepath.Join(r.dir, filepath.FromSlash(name)))
+	if err != nil {
+		return nil, fmt.Errorf("host template %q not found", name)
+	}
+
+	blocks := map[string]bool{}
+	if m := templateExtendsNamePattern.FindSubmatch(src); m != nil {
+		if blocks, err = r.hostBlocksLocked(string(m[1]), depth+1); err != nil {
+			return nil, err
+		}
+	}
+	for _, m := range templateBlockPattern.FindAllSubmatch(src, -1) {
+		blocks[string(m[1])] = true
+	}
+	return blocks, nil
+}
+
+// validateTemplateName rejects names that could leave the template directory
+// or break out of the generated {% extends %} tag.
+func validateTemplateName(name string) error {
+	if name == "" || path.IsAbs(name) || strings.ContainsAny(name, "\"\\") {
+		return fmt.Errorf("invalid template name %q", name)
+	}
+	for _, part := range strings.Split(name, "/") {
+		if part == ".." {
+			return fmt.Errorf("invalid template name %q", name)
+		}
+	}
+	return nil
 }
 
 // Unregister removes template overrides for a plugin.
 func (r *TemplateOverrideRegistry) Unregister(pluginName string) {
 	r.mu.Lock()
-	defer r.mu.Unlock()
+	changed := r.removeLocked(pluginName)
+	listener := r.listener
+	r.mu.Unlock()
+
+	notifyTemplateChange(listener, pluginName, changed)
+}
 
+// removeLocked drops a plugin's overrides and returns their template names.
+func (r *TemplateOverrideRegistry) removeLocked(pluginName string) []string {
+	var removed []string
 	for name, override := range r.overrides {
 		if override.PluginName == pluginName {
 			delete(r.overrides, name)
+			removed = append(removed, name)
 		}
 	}
+	return removed
+}
+
+// appendTemplateName appends name unless names already holds it.
+func appendTemplateName(names []string, name string) []string {
+	for _, n := range names {
+		if n == name {
+			return names
+		}
+	}
+	return append(names, name)
+}
+
+// notifyTemplateChange calls the listener when any template changed.
+func notifyTemplateChange(listener TemplateChangeListener, pluginName string, templates []string) {
+	if listener != nil && len(templates) > 0 {
+		listener(pluginName, templates)
+	}
 }
 
 // HasOverride checks if a template has a plugin override.
@@ -71,14 +260,35 @@ func (r *TemplateOverrideRegistry) GetOverride(templateName string) *TemplateOve
 	return r.overrides[templateName]
 }
 
-// RenderOverride renders a template override by calling the plugin.
+// RenderOverride renders a template override, executing its compiled source
+// or calling the plugin.
 // Returns the rendered HTML and true if an override exists, empty string and false otherwise.
 func (r *TemplateOverrideRegistry) RenderOverride(ctx context.Context, templateName string, data map[string]any) (string, bool) {
 	r.mu.RLock()
 	override, exists := r.overrides[templateName]
 	r.mu.RUnlock()
 
-	if !exists || r.manager == nil {
+	if !exists {
+		return "", false
+	}
+
+	// Compiled overrides are rendered by the host while the plugin is enabled
+	if override.Template != nil {
+		if r.manager != nil && !r.manager.IsEnabled(override.PluginName) {
+			return "", false
+		}
+		html, err := override.Template.Execute(pongo2.Context(data))
+		if err != nil {
+			// Log error but don't fail - fall back to host template
+			GetLogBuffer().Log(override.PluginName, "error",
+				"template override failed: "+err.Error(),
+				map[string]any{"template": templateName})
+			return "", false
+		}
+		return html, true
+	}
+
+	if r.manager == nil {
 		return "", false
 	}
 
@@ -4,6 +4,8 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -161,6 +163,7 @@ func TestTemplateOverrideWithMockManager(t *testing.T) {
 				enabled: true,
 			},
 		},
+		breakers: newBreakers(),
 	}
 	
 	registry := NewTemplateOverrideRegistry(mgr)
@@ -299,3 +302,185 @@ func TestRenderOverrideInvalidJSON(t *testing.T) {
 		t.Error("expected empty HTML on invalid JSON")
 	}
 }
+
+func TestTemplateOverrideCompiledSource(t *testing.T) {
+	registry := NewTemplateOverrideRegistry(nil)
+
+	err := registry.Register("source-plugin", []TemplateSpec{
+		{Name: "pages/hello.pongo2", Override: true, Source: "<p>Hello {{ Name }}</p>"},
+		{Name: "pages/broken.pongo2", Override: true, Source: "{% if %}"},
+	})
+	if err == nil {
+		t.Fatal("expected error for template that fails to parse")
+	}
+	if registry.HasOverride("pages/broken.pongo2") {
+		t.Error("template that fails to parse should be rejected")
+	}
+	if !registry.HasOverride("pages/hello.pongo2") {
+		t.Fatal("valid template should still be registered")
+	}
+
+	html, ok := registry.RenderOverride(context.Background(), "pages/hello.pongo2", map[string]any{"Name": "Ada"})
+	if !ok {
+		t.Fatal("expected compiled override to render")
+	}
+	if html != "<p>Hello Ada</p>" {
+		t.Errorf("expected rendered source, got %q", html)
+	}
+}
+
+func TestTemplateOverrideBlocks(t *testing.T) {
+	dir := t.TempDir()
+	writeTemplate(t, dir, "layouts/base.pongo2", `<html>{% block content %}base{% endblock %}|{% block footer %}footer{% endblock %}</html>`)
+	writeTemplate(t, dir, "pages/home.pongo2", `{% extends "layouts/base.pongo2" %}{% block content %}home{% endblock %}`)
+
+	registry := NewTemplateOverrideRegistry(nil)
+	if err := registry.SetTemplateDir(dir); err != nil {
+		t.Fatalf("SetTemplateDir: %v", err)
+	}
+
+	t.Run("replaces only listed blocks", func(t *testing.T) {
+		err := registry.Register("block-plugin", []TemplateSpec{
+			{Name: "pages/home.pongo2", Override: true, Blocks: []string{"footer"}, Source: `{% block footer %}{{ Text }}{% endblock %}`},
+		})
+		if err != nil {
+			t.Fatalf("Register: %v", err)
+		}
+		html, ok := registry.RenderOverride(context.Background(), "pages/home.pongo2", map[string]any{"Text": "plugin"})
+		if !ok {
+			t.Fatal("expected block override to render")
+		}
+		if html != "<html>home|plugin</html>" {
+			t.Errorf("expected host page with replaced footer, got %q", html)
+		}
+	})
+
+	rejected := []struct {
+		name string
+		spec TemplateSpec
+	}{
+		{"block not listed", TemplateSpec{Name: "pages/home.pongo2", Override: true, Blocks: []string{"footer"}, Source: `{% block content %}x{% endblock %}`}},
+		{"block missing in host", TemplateSpec{Name: "pages/home.pongo2", Override: true, Blocks: []string{"sidebar"}, Source: `{% block sidebar %}x{% endblock %}`}},
+		{"extends", TemplateSpec{Name: "pages/home.pongo2", Override: true, Blocks: []string{"footer"}, Source: `{% extends "layouts/base.pongo2" %}{% block footer %}x{% endblock %}`}},
+		{"host template missing", TemplateSpec{Name: "pages/missing.pongo2", Override: true, Blocks: []string{"footer"}, Source: `{% block footer %}x{% endblock %}`}},
+		{"path traversal", TemplateSpec{Name: "../secret.pongo2", Override: true, Blocks: []string{"footer"}, Source: `{% block footer %}x{% endblock %}`}},
+		{"blocks without source", TemplateSpec{Name: "pages/home.pongo2", Override: true, Blocks: []string{"footer"}}},
+	}
+	for _, tc := range rejected {
+		t.Run("rejects "+tc.name, func(t *testing.T) {
+			if err := registry.Register("bad-plugin", []TemplateSpec{tc.spec}); err == nil {
+				t.Error("expected template to be rejected")
+			}
+			if o := registry.GetOverride(tc.spec.Name); o != nil && o.PluginName == "bad-plugin" {
+				t.Error("rejected template should not be registered")
+			}
+		})
+	}
+}
+
+func TestTemplateOverrideReload(t *testing.T) {
+	registry := NewTemplateOverrideRegistry(nil)
+
+	var changes [][]string
+	registry.SetChangeListener(func(pluginName string, templates []string) {
+		if pluginName != "reload-plugin" {
+			t.Errorf("unexpected plugin %s", pluginName)
+		}
+		changes = append(changes, templates)
+	})
+
+	registry.Register("reload-plugin", []TemplateSpec{
+		{Name: "a.pongo2", Override: true, Source: "v1"},
+		{Name: "b.pongo2", Override: true, Source: "v1"},
+	})
+	registry.Register("reload-plugin", []TemplateSpec{
+		{Name: "a.pongo2", Override: true, Source: "v2"},
+	})
+
+	html, _ := registry.RenderOverride(context.Background(), "a.pongo2", nil)
+	if html != "v2" {
+		t.Errorf("expected re-registered source, got %q", html)
+	}
+	if registry.HasOverride("b.pongo2") {
+		t.Error("template dropped on re-register should be removed")
+	}
+	if len(changes) != 2 {
+		t.Fatalf("expected 2 change notifications, got %d", len(changes))
+	}
+	if len(changes[1]) != 2 {
+		t.Errorf("expected a and b in second notification, got %v", changes[1])
+	}
+
+	registry.Unregister("reload-plugin")
+	if len(changes) != 3 || len(changes[2]) != 1 || changes[2][0] != "a.pongo2" {
+		t.Errorf("expected unregister to report a.pongo2, got %v", changes)
+	}
+
+	registry.Unregister("reload-plugin")
+	if len(changes) != 3 {
+		t.Error("unregister without overrides should not notify")
+	}
+}
+
+// mockSourceTemplatePlugin declares a compiled template override
+type mockSourceTemplatePlugin struct{}
+
+func (m *mockSourceTemplatePlugin) GKRegister() GKRegistration {
+	return GKRegistration{
+		Name: "source-template",
+		Templates: []TemplateSpec{
+			{Name: "pages/source.pongo2", Override: true, Source: "plugin"},
+			{Name: "pages/broken.pongo2", Override: true, Source: "{% endif %}"},
+		},
+	}
+}
+
+func (m *mockSourceTemplatePlugin) Init(ctx context.Context, host HostAPI) error {
+	return nil
+}
+
+func (m *mockSourceTemplatePlugin) Call(ctx context.Context, fn string, args json.RawMessage) (json.RawMessage, error) {
+	return nil, nil
+}
+
+func (m *mockSourceTemplatePlugin) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+func TestManagerTemplateOverrideLifecycle(t *testing.T) {
+	original := GetTemplateOverrides()
+	defer SetTemplateOverrides(original)
+
+	ctx := context.Background()
+	mgr := NewManager(NewDefaultHostAPI())
+	registry := NewTemplateOverrideRegistry(mgr)
+	SetTemplateOverrides(registry)
+
+	if err := mgr.Register(ctx, &mockSourceTemplatePlugin{}); err != nil {
+		t.Fatalf("plugin with a rejected template should still register: %v", err)
+	}
+	if !registry.HasOverride("pages/source.pongo2") {
+		t.Error("valid template should be registered")
+	}
+	if registry.HasOverride("pages/broken.pongo2") {
+		t.Error("broken template should be rejected")
+	}
+
+	if err := mgr.Unregister(ctx, "source-template"); err != nil {
+		t.Fatalf("Unregister: %v", err)
+	}
+	if registry.HasOverride("pages/source.pongo2") {
+		t.Error("overrides should be removed with the plugin")
+	}
+}
+
+func writeTemplate(t *testing.T, dir, name, content string) {
+	t.Helper()
+	path := filepath.Join(dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -127,20 +127,11 @@ func (pc *PluginCaller) Widget(widgetID string) string {
 	manifest := p.GKRegister()
 	for _, w := range manifest.Widgets {
 		if w.ID == widgetID {
-			result, err := pc.Manager.Call(ctx, pc.PluginName, w.Handler, nil)
+			html, err := pc.Manager.RenderWidget(ctx, pc.PluginName, w)
 			if err != nil {
 				return fmt.Sprintf("<!-- widget error: %v -->", err)
 			}
-
-			var response map[string]interface{}
-			if err := json.Unmarshal(result, &response); err != nil {
-				return fmt.Sprintf("<!-- widget parse error: %v -->", err)
-			}
-
-			if html, ok := response["html"].(string); ok {
-				return html
-			}
-			return fmt.Sprintf("<!-- widget %q did not return html -->", widgetID)
+			return html
 		}
 	}
 
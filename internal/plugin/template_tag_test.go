@@ -344,6 +344,11 @@ func (m *mockHostAPIForTag) DBQuery(ctx context.Context, query string, args ...a
 func (m *mockHostAPIForTag) DBExec(ctx context.Context, query string, args ...any) (int64, error) {
 	return 0, nil
 }
+func (m *mockHostAPIForTag) DBBegin(ctx context.Context, dbName string) (string, error) {
+	return "tx-1", nil
+}
+func (m *mockHostAPIForTag) DBCommit(ctx context.Context, txID string) error   { return nil }
+func (m *mockHostAPIForTag) DBRollback(ctx context.Context, txID string) error { return nil }
 func (m *mockHostAPIForTag) CacheGet(ctx context.Context, key string) ([]byte, bool, error) {
 	return nil, false, nil
 }
@@ -351,6 +356,13 @@ func (m *mockHostAPIForTag) CacheSet(ctx context.Context, key string, value []by
 	return nil
 }
 func (m *mockHostAPIForTag) CacheDelete(ctx context.Context, key string) error { return nil }
+func (m *mockHostAPIForTag) FilePut(ctx context.Context, path string, content []byte) error {
+	return nil
+}
+func (m *mockHostAPIForTag) FileGet(ctx context.Context, path string) ([]byte, bool, error) {
+	return nil, false, nil
+}
+func (m *mockHostAPIForTag) FileDelete(ctx context.Context, path string) error { return nil }
 func (m *mockHostAPIForTag) HTTPRequest(ctx context.Context, method, url string, headers map[string]string, body []byte) (int, []byte, error) {
 	return 200, nil, nil
 }
@@ -361,6 +373,9 @@ func (m *mockHostAPIForTag) Log(ctx context.Context, level, message string, fiel
 func (m *mockHostAPIForTag) ConfigGet(ctx context.Context, key string) (string, error) {
 	return "", nil
 }
+func (m *mockHostAPIForTag) FeatureEnabled(ctx context.Context, flag string, userID int) (bool, error) {
+	return false, nil
+}
 func (m *mockHostAPIForTag) Translate(ctx context.Context, key string, args ...any) string {
 	return key
 }
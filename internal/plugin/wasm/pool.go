@@ -0,0 +1,131 @@
+package wasm
+
+import (
+	"context"
+	"sync"
+
+	"github.com/goatkit/goatflow/internal/plugin"
+)
+
+// instancePool hands out module instances to calls. It creates instances
+// on demand up to max and keeps them for later calls; closed instances are
+// dropped and replaced by the next call that needs one.
+type instancePool struct {
+	min, max    int
+	slots       chan struct{} // One token per instance serving a call
+	instantiate func(context.Context) (*instance, error)
+
+	mu      sync.Mutex
+	idle    []*instance
+	size    int // Live instances, idle or serving a call
+	waiting int
+	created uint64
+}
+
+func newInstancePool(min, max int, instantiate func(context.Context) (*instance, error)) *instancePool {
+	if min < 1 {
+		min = 1 // Registration needs an instance
+	}
+	if max < min {
+		max = min
+	}
+	return &instancePool{
+		min:         min,
+		max:         max,
+		slots:       make(chan struct{}, max),
+		instantiate: instantiate,
+	}
+}
+
+// fill creates the minimum number of instances.
+func (pl *instancePool) fill(ctx context.Context) error {
+	insts := make([]*instance, 0, pl.min)
+	defer func() {
+		for _, inst := range insts {
+			pl.put(inst)
+		}
+	}()
+	for i := 0; i < pl.min; i++ {
+		inst, err := pl.get(ctx)
+		if err != nil {
+			return err
+		}
+		insts = append(insts, inst)
+	}
+	return nil
+}
+
+// get returns an instance for one call, waiting while max instances are
+// serving calls. The instance must be handed back with put.
+func (pl *instancePool) get(ctx context.Context) (*instance, error) {
+	select {
+	case pl.slots <- struct{}{}:
+	default:
+		pl.mu.Lock()
+		pl.waiting++
+		pl.mu.Unlock()
+		select {
+		case pl.slots <- struct{}{}:
+		case <-ctx.Done():
+			pl.mu.Lock()
+			pl.waiting--
+			pl.mu.Unlock()
+			return nil, ctx.Err()
+		}
+		pl.mu.Lock()
+		pl.waiting--
+		pl.mu.Unlock()
+	}
+
+	pl.mu.Lock()
+	for len(pl.idle) > 0 {
+		inst := pl.idle[len(pl.idle)-1]
+		pl.idle = pl.idle[:len(pl.idle)-1]
+		if !inst.module.IsClosed() {
+			pl.mu.Unlock()
+			return inst, nil
+		}
+		pl.size--
+	}
+	pl.size++
+	pl.mu.Unlock()
+
+	inst, err := pl.instantiate(ctx)
+	pl.mu.Lock()
+	if err != nil {
+		pl.size--
+	} else {
+		pl.created++
+	}
+	pl.mu.Unlock()
+	if err != nil {
+		<-pl.slots
+		return nil, err
+	}
+	return inst, nil
+}
+
+// put hands an instance back after a call.
+func (pl *instancePool) put(inst *instance) {
+	pl.mu.Lock()
+	if inst.module.IsClosed() {
+		pl.size--
+	} else {
+		pl.idle = append(pl.idle, inst)
+	}
+	pl.mu.Unlock()
+	<-pl.slots
+}
+
+func (pl *instancePool) stats() plugin.PoolStats {
+	pl.mu.Lock()
+	defer pl.mu.Unlock()
+	return plugin.PoolStats{
+		Min:       pl.min,
+		Max:       pl.max,
+		Instances: pl.size,
+		InUse:     pl.size - len(pl.idle),
+		Waiting:   pl.waiting,
+		Created:   pl.created,
+	}
+}
@@ -17,8 +17,10 @@ package wasm
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
+	"strings"
 	"sync"
 	"time"
 
@@ -26,26 +28,36 @@ import (
 	"github.com/tetratelabs/wazero"
 	"github.com/tetratelabs/wazero/api"
 	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	"github.com/tetratelabs/wazero/sys"
 )
 
-// WASMPlugin implements plugin.Plugin for WASM modules.
+// WASMPlugin implements plugin.Plugin for WASM modules. Calls run on a
+// pool of module instances, each with its own memory, so concurrent calls
+// do not queue behind one another.
 type WASMPlugin struct {
 	mu       sync.Mutex
 	name     string
 	manifest plugin.GKRegistration
 	host     plugin.HostAPI
 
-	runtime wazero.Runtime
-	module  api.Module
+	runtime  wazero.Runtime
+	compiled wazero.CompiledModule
+	pool     *instancePool
+
+	// Resource limits
+	callTimeout time.Duration
+}
+
+// instance is one instantiation of the plugin module. It serves one call
+// at a time.
+type instance struct {
+	module api.Module
 
 	// Exported functions from the plugin
 	gkRegister api.Function
 	gkCall     api.Function
 	gkMalloc   api.Function
 	gkFree     api.Function
-
-	// Resource limits
-	callTimeout time.Duration
 }
 
 // LoadOption is a functional option for loading WASM plugins.
@@ -54,12 +66,16 @@ type LoadOption func(*loadOptions)
 type loadOptions struct {
 	memoryLimitPages uint32        // Memory limit in pages (64KB each)
 	callTimeout      time.Duration // Timeout for plugin function calls
+	minInstances     int           // Instances created at load
+	maxInstances     int           // Instances serving calls concurrently
 }
 
 func defaultLoadOptions() loadOptions {
 	return loadOptions{
 		memoryLimitPages: 256,              // 16MB default
 		callTimeout:      30 * time.Second, // 30s default timeout
+		minInstances:     1,
+		maxInstances:     4,
 	}
 }
 
@@ -79,6 +95,16 @@ func WithCallTimeout(d time.Duration) LoadOption {
 	}
 }
 
+// WithInstancePool sets how many module instances are created at load and
+// how many may serve calls concurrently. Each instance has its own memory,
+// up to the memory limit. Default is 1 and 4.
+func WithInstancePool(min, max int) LoadOption {
+	return func(o *loadOptions) {
+		o.minInstances = min
+		o.maxInstances = max
+	}
+}
+
 // LoadFromFile loads a WASM plugin from a file path.
 func LoadFromFile(ctx context.Context, path string, opts ...LoadOption) (*WASMPlugin, error) {
 	wasmBytes, err := os.ReadFile(path)
@@ -95,9 +121,11 @@ func Load(ctx context.Context, wasmBytes []byte, opts ...LoadOption) (*WASMPlugi
 		opt(&options)
 	}
 
-	// Create runtime with memory limits
+	// Create runtime with memory limits. Calls stop when their context is
+	// done, which closes the module; the next call instantiates it again.
 	runtimeConfig := wazero.NewRuntimeConfig().
-		WithMemoryLimitPages(options.memoryLimitPages)
+		WithMemoryLimitPages(options.memoryLimitPages).
+		WithCloseOnContextDone(true)
 	r := wazero.NewRuntimeWithConfig(ctx, runtimeConfig)
 
 	// Instantiate WASI for plugins that need it (filesystem, env, etc.)
@@ -114,32 +142,27 @@ func Load(ctx context.Context, wasmBytes []byte, opts ...LoadOption) (*WASMPlugi
 		return nil, fmt.Errorf("define host functions: %w", err)
 	}
 
-	// Compile and instantiate the module
-	module, err := r.Instantiate(ctx, wasmBytes)
+	// Compile the module and create the initial instances
+	compiled, err := r.CompileModule(ctx, wasmBytes)
 	if err != nil {
 		r.Close(ctx)
 		return nil, fmt.Errorf("instantiate wasm: %w", err)
 	}
-	p.module = module
-
-	// Get required exports
-	p.gkRegister = module.ExportedFunction("gk_register")
-	p.gkCall = module.ExportedFunction("gk_call")
-	p.gkMalloc = module.ExportedFunction("gk_malloc")
-	p.gkFree = module.ExportedFunction("gk_free")
-
-	if p.gkRegister == nil {
-		return nil, fmt.Errorf("plugin missing gk_register export")
-	}
-	if p.gkCall == nil {
-		return nil, fmt.Errorf("plugin missing gk_call export")
-	}
-	if p.gkMalloc == nil {
-		return nil, fmt.Errorf("plugin missing gk_malloc export")
+	p.compiled = compiled
+	p.pool = newInstancePool(options.minInstances, options.maxInstances, p.instantiate)
+	if err := p.pool.fill(ctx); err != nil {
+		r.Close(ctx)
+		return nil, err
 	}
 
 	// Call gk_register to get manifest
-	manifest, err := p.callRegister(ctx)
+	inst, err := p.pool.get(ctx)
+	if err != nil {
+		r.Close(ctx)
+		return nil, err
+	}
+	manifest, err := p.callRegister(ctx, inst)
+	p.pool.put(inst)
 	if err != nil {
 		r.Close(ctx)
 		return nil, fmt.Errorf("gk_register failed: %w", err)
@@ -150,6 +173,40 @@ func Load(ctx context.Context, wasmBytes []byte, opts ...LoadOption) (*WASMPlugi
 	return p, nil
 }
 
+// instantiate creates a module instance from the compiled plugin. Instances
+// are anonymous so the module can be instantiated more than once.
+func (p *WASMPlugin) instantiate(ctx context.Context) (*instance, error) {
+	module, err := p.runtime.InstantiateModule(ctx, p.compiled, wazero.NewModuleConfig().WithName(""))
+	if err != nil {
+		return nil, fmt.Errorf("instantiate wasm: %w", err)
+	}
+	inst := bind(module)
+	if inst.gkRegister == nil {
+		module.Close(ctx)
+		return nil, fmt.Errorf("plugin missing gk_register export")
+	}
+	if inst.gkCall == nil {
+		module.Close(ctx)
+		return nil, fmt.Errorf("plugin missing gk_call export")
+	}
+	if inst.gkMalloc == nil {
+		module.Close(ctx)
+		return nil, fmt.Errorf("plugin missing gk_malloc export")
+	}
+	return inst, nil
+}
+
+// bind looks up the exports of a module instance.
+func bind(module api.Module) *instance {
+	return &instance{
+		module:     module,
+		gkRegister: module.ExportedFunction("gk_register"),
+		gkCall:     module.ExportedFunction("gk_call"),
+		gkMalloc:   module.ExportedFunction("gk_malloc"),
+		gkFree:     module.ExportedFunction("gk_free"),
+	}
+}
+
 // defineHostFunctions registers host API functions the plugin can call.
 func (p *WASMPlugin) defineHostFunctions(ctx context.Context) error {
 	_, err := p.runtime.NewHostModuleBuilder("gk").
@@ -163,21 +220,23 @@ func (p *WASMPlugin) defineHostFunctions(ctx context.Context) error {
 	return err
 }
 
-// hostCall handles plugin calls to host API.
+// hostCall handles plugin calls to host API. Memory is read from and
+// written to the calling instance.
 // Signature: host_call(fn_ptr, fn_len, args_ptr, args_len) -> result_ptr
-func (p *WASMPlugin) hostCall(ctx context.Context, fnPtr, fnLen, argsPtr, argsLen uint32) uint64 {
+func (p *WASMPlugin) hostCall(ctx context.Context, mod api.Module, fnPtr, fnLen, argsPtr, argsLen uint32) uint64 {
 	if p.host == nil {
 		return 0
 	}
+	inst := bind(mod)
 
 	// Read function name from plugin memory
-	fnName, ok := p.readString(fnPtr, fnLen)
+	fnName, ok := inst.readString(fnPtr, fnLen)
 	if !ok {
 		return 0
 	}
 
 	// Read args from plugin memory
-	args, ok := p.readBytes(argsPtr, argsLen)
+	args, ok := inst.readBytes(argsPtr, argsLen)
 	if !ok {
 		return 0
 	}
@@ -196,17 +255,17 @@ func (p *WASMPlugin) hostCall(ctx context.Context, fnPtr, fnLen, argsPtr, argsLe
 	}
 
 	// Write result to plugin memory
-	return p.writeBytes(result)
+	return inst.writeBytes(result)
 }
 
 // hostLog handles plugin logging.
 // Signature: log(level, msg_ptr, msg_len)
-func (p *WASMPlugin) hostLog(ctx context.Context, level uint32, msgPtr, msgLen uint32) {
+func (p *WASMPlugin) hostLog(ctx context.Context, mod api.Module, level uint32, msgPtr, msgLen uint32) {
 	if p.host == nil {
 		return
 	}
 
-	msg, ok := p.readString(msgPtr, msgLen)
+	msg, ok := (&instance{module: mod}).readString(msgPtr, msgLen)
 	if !ok {
 		return
 	}
@@ -233,11 +292,12 @@ func (p *WASMPlugin) dispatchHostCall(ctx context.Context, fn string, args []byt
 		var req struct {
 			Query string `json:"query"`
 			Args  []any  `json:"args"`
+			Tx    string `json:"tx"`
 		}
 		if err := json.Unmarshal(args, &req); err != nil {
 			return nil, err
 		}
-		rows, err := p.host.DBQuery(ctx, req.Query, req.Args...)
+		rows, err := p.host.DBQuery(plugin.WithTxID(ctx, req.Tx), req.Query, req.Args...)
 		if err != nil {
 			return nil, err
 		}
@@ -247,16 +307,50 @@ func (p *WASMPlugin) dispatchHostCall(ctx context.Context, fn string, args []byt
 		var req struct {
 			Query string `json:"query"`
 			Args  []any  `json:"args"`
+			Tx    string `json:"tx"`
 		}
 		if err := json.Unmarshal(args, &req); err != nil {
 			return nil, err
 		}
-		affected, err := p.host.DBExec(ctx, req.Query, req.Args...)
+		affected, err := p.host.DBExec(plugin.WithTxID(ctx, req.Tx), req.Query, req.Args...)
 		if err != nil {
 			return nil, err
 		}
 		return json.Marshal(map[string]int64{"affected": affected})
 
+	case "db_begin":
+		var req struct {
+			Database string `json:"database"`
+		}
+		if len(args) > 0 {
+			if err := json.Unmarshal(args, &req); err != nil {
+				return nil, err
+			}
+		}
+		txID, err := p.host.DBBegin(ctx, req.Database)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(map[string]string{"tx": txID})
+
+	case "db_commit", "db_rollback":
+		var req struct {
+			Tx string `json:"tx"`
+		}
+		if err := json.Unmarshal(args, &req); err != nil {
+			return nil, err
+		}
+		var err error
+		if fn == "db_commit" {
+			err = p.host.DBCommit(ctx, req.Tx)
+		} else {
+			err = p.host.DBRollback(ctx, req.Tx)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(map[string]bool{"ok": true})
+
 	case "cache_get":
 		var req struct {
 			Key string `json:"key"`
@@ -285,6 +379,44 @@ func (p *WASMPlugin) dispatchHostCall(ctx context.Context, fn string, args []byt
 		}
 		return json.Marshal(map[string]bool{"ok": true})
 
+	case "file_put":
+		var req struct {
+			Path    string `json:"path"`
+			Content []byte `json:"content"`
+		}
+		if err := json.Unmarshal(args, &req); err != nil {
+			return nil, err
+		}
+		if err := p.host.FilePut(ctx, req.Path, req.Content); err != nil {
+			return nil, err
+		}
+		return json.Marshal(map[string]bool{"ok": true})
+
+	case "file_get":
+		var req struct {
+			Path string `json:"path"`
+		}
+		if err := json.Unmarshal(args, &req); err != nil {
+			return nil, err
+		}
+		content, found, err := p.host.FileGet(ctx, req.Path)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(map[string]any{"content": content, "found": found})
+
+	case "file_delete":
+		var req struct {
+			Path string `json:"path"`
+		}
+		if err := json.Unmarshal(args, &req); err != nil {
+			return nil, err
+		}
+		if err := p.host.FileDelete(ctx, req.Path); err != nil {
+			return nil, err
+		}
+		return json.Marshal(map[string]bool{"ok": true})
+
 	case "http_request":
 		var req struct {
 			Method  string            `json:"method"`
@@ -330,6 +462,20 @@ func (p *WASMPlugin) dispatchHostCall(ctx context.Context, fn string, args []byt
 		}
 		return json.Marshal(map[string]string{"value": val})
 
+	case "feature_enabled":
+		var req struct {
+			Flag   string `json:"flag"`
+			UserID int    `json:"user_id"`
+		}
+		if err := json.Unmarshal(args, &req); err != nil {
+			return nil, err
+		}
+		on, err := p.host.FeatureEnabled(ctx, req.Flag, req.UserID)
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(map[string]bool{"enabled": on})
+
 	case "translate":
 		var req struct {
 			Key  string `json:"key"`
@@ -362,8 +508,8 @@ func (p *WASMPlugin) dispatchHostCall(ctx context.Context, fn string, args []byt
 }
 
 // callRegister calls gk_register and parses the manifest.
-func (p *WASMPlugin) callRegister(ctx context.Context) (plugin.GKRegistration, error) {
-	results, err := p.gkRegister.Call(ctx)
+func (p *WASMPlugin) callRegister(ctx context.Context, inst *instance) (plugin.GKRegistration, error) {
+	results, err := inst.gkRegister.Call(ctx)
 	if err != nil {
 		return plugin.GKRegistration{}, err
 	}
@@ -376,7 +522,7 @@ func (p *WASMPlugin) callRegister(ctx context.Context) (plugin.GKRegistration, e
 	ptr := uint32(packed >> 32)
 	length := uint32(packed & 0xFFFFFFFF)
 
-	jsonBytes, ok := p.readBytes(ptr, length)
+	jsonBytes, ok := inst.readBytes(ptr, length)
 	if !ok {
 		return plugin.GKRegistration{}, fmt.Errorf("failed to read manifest from memory")
 	}
@@ -402,11 +548,9 @@ func (p *WASMPlugin) Init(ctx context.Context, host plugin.HostAPI) error {
 	return nil
 }
 
-// Call implements plugin.Plugin.
+// Call implements plugin.Plugin. It runs on an idle instance, or a new one
+// while the pool is below its maximum, and otherwise waits for one.
 func (p *WASMPlugin) Call(ctx context.Context, fn string, args json.RawMessage) (json.RawMessage, error) {
-	p.mu.Lock()
-	defer p.mu.Unlock()
-
 	// Apply timeout if configured and not already set on context
 	if p.callTimeout > 0 {
 		var cancel context.CancelFunc
@@ -414,29 +558,40 @@ func (p *WASMPlugin) Call(ctx context.Context, fn string, args json.RawMessage)
 		defer cancel()
 	}
 
+	inst, err := p.pool.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// A call interrupted by its context, or a module exiting, closes the
+	// instance; the pool drops it and later calls get fresh memory
+	defer p.pool.put(inst)
+
 	// Allocate and write function name
-	fnPtr := p.writeString(fn)
+	fnPtr := inst.writeString(fn)
 	if fnPtr == 0 {
 		return nil, fmt.Errorf("failed to allocate memory for function name")
 	}
-	defer p.free(uint32(fnPtr >> 32))
+	defer inst.free(uint32(fnPtr >> 32))
 
 	// Allocate and write args (nil/empty args is valid - pass 0, 0)
 	var argsPtr uint64
 	if len(args) > 0 {
-		argsPtr = p.writeBytes(args)
+		argsPtr = inst.writeBytes(args)
 		if argsPtr == 0 {
 			return nil, fmt.Errorf("failed to allocate memory for args")
 		}
-		defer p.free(uint32(argsPtr >> 32))
+		defer inst.free(uint32(argsPtr >> 32))
 	}
 
 	// Call gk_call(fn_ptr, fn_len, args_ptr, args_len)
-	results, err := p.gkCall.Call(ctx,
+	results, err := inst.gkCall.Call(ctx,
 		uint64(fnPtr>>32), uint64(fnPtr&0xFFFFFFFF),
 		uint64(argsPtr>>32), uint64(argsPtr&0xFFFFFFFF),
 	)
 	if err != nil {
+		if crash := crashFromCallError(err); crash != nil {
+			return nil, crash
+		}
 		return nil, fmt.Errorf("gk_call failed: %w", err)
 	}
 	if len(results) == 0 {
@@ -452,25 +607,58 @@ func (p *WASMPlugin) Call(ctx context.Context, fn string, args json.RawMessage)
 		return nil, nil
 	}
 
-	result, ok := p.readBytes(ptr, length)
+	result, ok := inst.readBytes(ptr, length)
 	if !ok {
 		return nil, fmt.Errorf("failed to read result from memory")
 	}
 
 	// Free result memory
-	p.free(ptr)
+	inst.free(ptr)
 
 	return result, nil
 }
 
+// Stats implements plugin.StatsReporter with the instance pool's figures.
+func (p *WASMPlugin) Stats() plugin.PluginStats {
+	if p.pool == nil {
+		return plugin.PluginStats{}
+	}
+	stats := p.pool.stats()
+	return plugin.PluginStats{Pool: &stats}
+}
+
+// wasmStackTraceMarker separates the reason from the stack in trap errors.
+const wasmStackTraceMarker = "\nwasm stack trace:\n"
+
+// crashFromCallError returns a CrashError for a trap, or for the module
+// exiting mid-call, and nil for other errors such as a call timeout.
+func crashFromCallError(err error) *plugin.CrashError {
+	var exitErr *sys.ExitError
+	if errors.As(err, &exitErr) {
+		switch exitErr.ExitCode() {
+		case sys.ExitCodeDeadlineExceeded, sys.ExitCodeContextCanceled:
+			return nil
+		}
+		return &plugin.CrashError{Runtime: plugin.CrashRuntimeWASM, Reason: exitErr.Error(), Err: err}
+	}
+	reason, stack, ok := strings.Cut(err.Error(), wasmStackTraceMarker)
+	if !ok {
+		return nil
+	}
+	return &plugin.CrashError{
+		Runtime: plugin.CrashRuntimeWASM,
+		Reason:  reason,
+		Stack:   strings.ReplaceAll(strings.TrimPrefix(stack, "\t"), "\n\t", "\n"),
+		Err:     err,
+	}
+}
+
 // Shutdown implements plugin.Plugin.
 func (p *WASMPlugin) Shutdown(ctx context.Context) error {
 	p.mu.Lock()
 	defer p.mu.Unlock()
 
-	if p.module != nil {
-		p.module.Close(ctx)
-	}
+	// Closing the runtime closes every instance
 	if p.runtime != nil {
 		return p.runtime.Close(ctx)
 	}
@@ -479,36 +667,36 @@ func (p *WASMPlugin) Shutdown(ctx context.Context) error {
 
 // Memory helpers
 
-func (p *WASMPlugin) readString(ptr, length uint32) (string, bool) {
-	bytes, ok := p.readBytes(ptr, length)
+func (inst *instance) readString(ptr, length uint32) (string, bool) {
+	bytes, ok := inst.readBytes(ptr, length)
 	if !ok {
 		return "", false
 	}
 	return string(bytes), true
 }
 
-func (p *WASMPlugin) readBytes(ptr, length uint32) ([]byte, bool) {
-	if p.module == nil {
+func (inst *instance) readBytes(ptr, length uint32) ([]byte, bool) {
+	if inst.module == nil {
 		return nil, false
 	}
-	mem := p.module.Memory()
+	mem := inst.module.Memory()
 	if mem == nil {
 		return nil, false
 	}
 	return mem.Read(ptr, length)
 }
 
-func (p *WASMPlugin) writeString(s string) uint64 {
-	return p.writeBytes([]byte(s))
+func (inst *instance) writeString(s string) uint64 {
+	return inst.writeBytes([]byte(s))
 }
 
-func (p *WASMPlugin) writeBytes(data []byte) uint64 {
+func (inst *instance) writeBytes(data []byte) uint64 {
 	if len(data) == 0 {
 		return 0
 	}
 
 	// Allocate memory in plugin
-	results, err := p.gkMalloc.Call(context.Background(), uint64(len(data)))
+	results, err := inst.gkMalloc.Call(context.Background(), uint64(len(data)))
 	if err != nil || len(results) == 0 {
 		return 0
 	}
@@ -518,7 +706,7 @@ func (p *WASMPlugin) writeBytes(data []byte) uint64 {
 	}
 
 	// Write data
-	mem := p.module.Memory()
+	mem := inst.module.Memory()
 	if mem == nil {
 		return 0
 	}
@@ -530,8 +718,8 @@ func (p *WASMPlugin) writeBytes(data []byte) uint64 {
 	return (uint64(ptr) << 32) | uint64(len(data))
 }
 
-func (p *WASMPlugin) free(ptr uint32) {
-	if p.gkFree != nil && ptr != 0 {
-		p.gkFree.Call(context.Background(), uint64(ptr))
+func (inst *instance) free(ptr uint32) {
+	if inst.gkFree != nil && ptr != 0 {
+		inst.gkFree.Call(context.Background(), uint64(ptr))
 	}
 }
@@ -3,7 +3,14 @@ package wasm
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
 	"testing"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/sys"
 
 	"github.com/goatkit/goatflow/internal/plugin"
 )
@@ -17,6 +24,11 @@ func (m *mockHostAPIForUnit) DBQuery(ctx context.Context, query string, args ...
 func (m *mockHostAPIForUnit) DBExec(ctx context.Context, query string, args ...any) (int64, error) {
 	return 0, nil
 }
+func (m *mockHostAPIForUnit) DBBegin(ctx context.Context, dbName string) (string, error) {
+	return "tx-1", nil
+}
+func (m *mockHostAPIForUnit) DBCommit(ctx context.Context, txID string) error   { return nil }
+func (m *mockHostAPIForUnit) DBRollback(ctx context.Context, txID string) error { return nil }
 func (m *mockHostAPIForUnit) CacheGet(ctx context.Context, key string) ([]byte, bool, error) {
 	return nil, false, nil
 }
@@ -26,6 +38,15 @@ func (m *mockHostAPIForUnit) CacheSet(ctx context.Context, key string, value []b
 func (m *mockHostAPIForUnit) CacheDelete(ctx context.Context, key string) error {
 	return nil
 }
+func (m *mockHostAPIForUnit) FilePut(ctx context.Context, path string, content []byte) error {
+	return nil
+}
+func (m *mockHostAPIForUnit) FileGet(ctx context.Context, path string) ([]byte, bool, error) {
+	return nil, false, nil
+}
+func (m *mockHostAPIForUnit) FileDelete(ctx context.Context, path string) error {
+	return nil
+}
 func (m *mockHostAPIForUnit) HTTPRequest(ctx context.Context, method, url string, headers map[string]string, body []byte) (int, []byte, error) {
 	return 200, nil, nil
 }
@@ -36,6 +57,9 @@ func (m *mockHostAPIForUnit) Log(ctx context.Context, level, message string, fie
 func (m *mockHostAPIForUnit) ConfigGet(ctx context.Context, key string) (string, error) {
 	return "", nil
 }
+func (m *mockHostAPIForUnit) FeatureEnabled(ctx context.Context, flag string, userID int) (bool, error) {
+	return false, nil
+}
 func (m *mockHostAPIForUnit) Translate(ctx context.Context, key string, args ...any) string {
 	return ""
 }
@@ -48,7 +72,7 @@ func TestHostCallWithNilHost(t *testing.T) {
 		host: nil, // nil host
 	}
 
-	result := p.hostCall(context.Background(), 0, 0, 0, 0)
+	result := p.hostCall(context.Background(), nil, 0, 0, 0, 0)
 	if result != 0 {
 		t.Errorf("expected 0 for nil host, got %d", result)
 	}
@@ -60,15 +84,13 @@ func TestHostLogWithNilHost(t *testing.T) {
 	}
 
 	// Should not panic
-	p.hostLog(context.Background(), 1, 0, 0)
+	p.hostLog(context.Background(), nil, 1, 0, 0)
 }
 
 func TestReadBytesWithNilModule(t *testing.T) {
-	p := &WASMPlugin{
-		module: nil, // nil module
-	}
+	inst := &instance{} // nil module
 
-	bytes, ok := p.readBytes(100, 10)
+	bytes, ok := inst.readBytes(100, 10)
 	if ok {
 		t.Error("expected ok=false for nil module")
 	}
@@ -78,11 +100,9 @@ func TestReadBytesWithNilModule(t *testing.T) {
 }
 
 func TestReadStringWithNilModule(t *testing.T) {
-	p := &WASMPlugin{
-		module: nil,
-	}
+	inst := &instance{} // nil module
 
-	s, ok := p.readString(100, 10)
+	s, ok := inst.readString(100, 10)
 	if ok {
 		t.Error("expected ok=false for nil module")
 	}
@@ -92,41 +112,35 @@ func TestReadStringWithNilModule(t *testing.T) {
 }
 
 func TestWriteBytesEmpty(t *testing.T) {
-	p := &WASMPlugin{
-		module: nil,
-	}
+	inst := &instance{} // nil module
 
 	// Empty data should return 0
-	result := p.writeBytes(nil)
+	result := inst.writeBytes(nil)
 	if result != 0 {
 		t.Errorf("expected 0 for empty data, got %d", result)
 	}
 
-	result = p.writeBytes([]byte{})
+	result = inst.writeBytes([]byte{})
 	if result != 0 {
 		t.Errorf("expected 0 for empty slice, got %d", result)
 	}
 }
 
 func TestWriteStringEmpty(t *testing.T) {
-	p := &WASMPlugin{
-		module: nil,
-	}
+	inst := &instance{} // nil module
 
-	result := p.writeString("")
+	result := inst.writeString("")
 	if result != 0 {
 		t.Errorf("expected 0 for empty string, got %d", result)
 	}
 }
 
 func TestFreeWithNilFunction(t *testing.T) {
-	p := &WASMPlugin{
-		gkFree: nil,
-	}
+	inst := &instance{gkFree: nil}
 
 	// Should not panic
-	p.free(0)
-	p.free(100)
+	inst.free(0)
+	inst.free(100)
 }
 
 func TestDispatchHostCallUnknown(t *testing.T) {
@@ -155,7 +169,7 @@ func TestDispatchHostCallInvalidJSON(t *testing.T) {
 	// Test each method with invalid JSON
 	methods := []string{
 		"db_query", "db_exec", "cache_get", "cache_set",
-		"http_request", "send_email", "config_get", "translate", "plugin_call",
+		"file_put", "file_get", "file_delete", "http_request", "send_email", "config_get", "feature_enabled", "translate", "plugin_call",
 	}
 
 	for _, method := range methods {
@@ -203,4 +217,189 @@ func TestWithCallTimeout(t *testing.T) {
 	}
 }
 
+// unreachableWASM exports "boom", which executes the unreachable instruction.
+var unreachableWASM = []byte{
+	0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00, // magic, version
+	0x01, 0x04, 0x01, 0x60, 0x00, 0x00, // type section: func()
+	0x03, 0x02, 0x01, 0x00, // function section
+	0x07, 0x08, 0x01, 0x04, 'b', 'o', 'o', 'm', 0x00, 0x00, // export "boom"
+	0x0a, 0x05, 0x01, 0x03, 0x00, 0x00, 0x0b, // code: unreachable, end
+}
+
+func TestCrashFromCallError(t *testing.T) {
+	ctx := context.Background()
+	r := wazero.NewRuntime(ctx)
+	defer r.Close(ctx)
+	mod, err := r.Instantiate(ctx, unreachableWASM)
+	if err != nil {
+		t.Fatalf("instantiate: %v", err)
+	}
+
+	_, trapErr := mod.ExportedFunction("boom").Call(ctx)
+	crash := crashFromCallError(trapErr)
+	if crash == nil {
+		t.Fatalf("expected a crash for %v", trapErr)
+	}
+	if crash.Runtime != plugin.CrashRuntimeWASM || crash.Reason != "wasm error: unreachable" {
+		t.Errorf("unexpected crash %+v", crash)
+	}
+	if !strings.HasPrefix(crash.Stack, ".$0()") {
+		t.Errorf("expected the stack to start at the trapping function, got %q", crash.Stack)
+	}
+
+	if crash := crashFromCallError(sys.NewExitError(sys.ExitCodeDeadlineExceeded)); crash != nil {
+		t.Errorf("a timeout is not a crash, got %+v", crash)
+	}
+	if crash := crashFromCallError(sys.NewExitError(2)); crash == nil {
+		t.Error("expected a crash when the module exits mid-call")
+	}
+	if crash := crashFromCallError(errors.New("unknown function")); crash != nil {
+		t.Errorf("plain errors are not crashes, got %+v", crash)
+	}
+}
+
+// spinnerWASM is a minimal plugin whose gk_call loops forever when the
+// function name is four bytes long ("spin") and returns nothing otherwise.
+func spinnerWASM() []byte {
+	manifest := []byte(`{"name":"spinner","version":"1.0.0"}`)
+	section := func(id byte, body ...byte) []byte {
+		return append([]byte{id, byte(len(body))}, body...)
+	}
+	body := func(code ...byte) []byte {
+		return append([]byte{byte(len(code) + 1), 0x00}, code...) // no locals
+	}
+	name := func(s string) []byte { return append([]byte{byte(len(s))}, s...) }
+
+	// gk_register returns 16<<32 | len(manifest) as a signed LEB128 i64.const
+	packed := append([]byte{0x42}, 0x80|byte(len(manifest)), 0x80, 0x80, 0x80, 0x80, 0x02, 0x0b)
+	var code []byte
+	code = append(code, 4)
+	code = append(code, body(packed...)...)
+	code = append(code, body(0x20, 0x01, 0x41, 0x04, 0x46, 0x04, 0x40, 0x03, 0x40, 0x0c, 0x00, 0x0b, 0x0b, 0x42, 0x00, 0x0b)...)
+	code = append(code, body(0x41, 0x80, 0x08, 0x0b)...) // gk_malloc: 1024
+	code = append(code, body(0x0b)...)                   // gk_free
+
+	var exports []byte
+	exports = append(exports, 5)
+	exports = append(append(exports, name("memory")...), 0x02, 0x00)
+	for i, fn := range []string{"gk_register", "gk_call", "gk_malloc", "gk_free"} {
+		exports = append(append(exports, name(fn)...), 0x00, byte(i))
+	}
+	data := append([]byte{0x01, 0x00, 0x41, 0x10, 0x0b, byte(len(manifest))}, manifest...)
+
+	module := []byte{0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00}
+	module = append(module, section(0x01, 0x04,
+		0x60, 0x00, 0x01, 0x7e, // () -> i64
+		0x60, 0x04, 0x7f, 0x7f, 0x7f, 0x7f, 0x01, 0x7e, // (i32 x4) -> i64
+		0x60, 0x01, 0x7f, 0x01, 0x7f, // (i32) -> i32
+		0x60, 0x01, 0x7f, 0x00, // (i32) -> ()
+	)...)
+	module = append(module, section(0x03, 0x04, 0x00, 0x01, 0x02, 0x03)...)
+	module = append(module, section(0x05, 0x01, 0x00, 0x01)...)
+	module = append(module, section(0x07, exports...)...)
+	module = append(module, section(0x0a, code...)...)
+	module = append(module, section(0x0b, data...)...)
+	return module
+}
+
+func TestCallInterruptedByContext(t *testing.T) {
+	ctx := context.Background()
+	p, err := Load(ctx, spinnerWASM(), WithCallTimeout(time.Minute))
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	defer p.Shutdown(ctx)
+	if p.GKRegister().Name != "spinner" {
+		t.Fatalf("unexpected manifest %+v", p.GKRegister())
+	}
+
+	callCtx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = p.Call(callCtx, "spin", nil)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected a deadline error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("the spinning call was not interrupted, took %s", elapsed)
+	}
+
+	// The closed instance is replaced on the next call
+	if _, err := p.Call(ctx, "ok", nil); err != nil {
+		t.Errorf("call after an interrupted call failed: %v", err)
+	}
+
+	// The load option bounds calls without a deadline of their own
+	short, err := Load(ctx, spinnerWASM(), WithCallTimeout(50*time.Millisecond))
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	defer short.Shutdown(ctx)
+	if _, err := short.Call(ctx, "spin", nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the call timeout to interrupt the call, got %v", err)
+	}
+}
+
+func TestInstancePool(t *testing.T) {
+	ctx := context.Background()
+	p, err := Load(ctx, spinnerWASM(), WithInstancePool(2, 3))
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	defer p.Shutdown(ctx)
+	if st := p.Stats().Pool; st == nil || st.Min != 2 || st.Max != 3 || st.Instances != 2 || st.InUse != 0 {
+		t.Fatalf("expected 2 idle instances after load, got %+v", st)
+	}
+
+	// Spinning calls occupy all three instances at once
+	var wg sync.WaitGroup
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			callCtx, cancel := context.WithTimeout(ctx, 200*time.Millisecond)
+			defer cancel()
+			_, _ = p.Call(callCtx, "spin", nil)
+		}()
+	}
+
+	// A fourth call waits for an instance
+	deadline := time.Now().Add(time.Second)
+	for p.Stats().Pool.InUse < 3 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	waitCtx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	if _, err := p.Call(waitCtx, "ok", nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the fourth call to time out waiting, got %v", err)
+	}
+	wg.Wait()
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("expected concurrent calls to run in parallel, took %s", elapsed)
+	}
+
+	// Interrupted instances are dropped and replaced on demand
+	if st := p.Stats().Pool; st.Instances != 0 || st.InUse != 0 || st.Waiting != 0 || st.Created != 3 {
+		t.Errorf("expected the interrupted instances to be dropped, got %+v", st)
+	}
+	if _, err := p.Call(ctx, "ok", nil); err != nil {
+		t.Fatalf("call after interrupted calls failed: %v", err)
+	}
+	if st := p.Stats().Pool; st.Instances != 1 || st.Created != 4 {
+		t.Errorf("expected a fresh instance, got %+v", st)
+	}
+}
+
+func TestNewInstancePoolBounds(t *testing.T) {
+	pl := newInstancePool(0, 0, nil)
+	if pl.min != 1 || pl.max != 1 {
+		t.Errorf("expected at least one instance, got min %d max %d", pl.min, pl.max)
+	}
+	pl = newInstancePool(3, 2, nil)
+	if pl.max != 3 {
+		t.Errorf("expected max raised to min, got %d", pl.max)
+	}
+}
+
 var _ plugin.HostAPI = (*mockHostAPIForUnit)(nil)
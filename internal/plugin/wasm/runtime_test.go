@@ -21,6 +21,11 @@ func (m *mockHostAPI) DBQuery(ctx context.Context, query string, args ...any) ([
 func (m *mockHostAPI) DBExec(ctx context.Context, query string, args ...any) (int64, error) {
 	return 0, nil
 }
+func (m *mockHostAPI) DBBegin(ctx context.Context, dbName string) (string, error) {
+	return "tx-1", nil
+}
+func (m *mockHostAPI) DBCommit(ctx context.Context, txID string) error   { return nil }
+func (m *mockHostAPI) DBRollback(ctx context.Context, txID string) error { return nil }
 func (m *mockHostAPI) CacheGet(ctx context.Context, key string) ([]byte, bool, error) {
 	return nil, false, nil
 }
@@ -30,6 +35,15 @@ func (m *mockHostAPI) CacheSet(ctx context.Context, key string, value []byte, tt
 func (m *mockHostAPI) CacheDelete(ctx context.Context, key string) error {
 	return nil
 }
+func (m *mockHostAPI) FilePut(ctx context.Context, path string, content []byte) error {
+	return nil
+}
+func (m *mockHostAPI) FileGet(ctx context.Context, path string) ([]byte, bool, error) {
+	return nil, false, nil
+}
+func (m *mockHostAPI) FileDelete(ctx context.Context, path string) error {
+	return nil
+}
 func (m *mockHostAPI) HTTPRequest(ctx context.Context, method, url string, headers map[string]string, body []byte) (int, []byte, error) {
 	return 200, nil, nil
 }
@@ -38,6 +52,7 @@ func (m *mockHostAPI) SendEmail(ctx context.Context, to, subject, body string, h
 }
 func (m *mockHostAPI) Log(ctx context.Context, level, message string, fields map[string]any) {}
 func (m *mockHostAPI) ConfigGet(ctx context.Context, key string) (string, error)            { return "", nil }
+func (m *mockHostAPI) FeatureEnabled(ctx context.Context, flag string, userID int) (bool, error) { return false, nil }
 func (m *mockHostAPI) Translate(ctx context.Context, key string, args ...any) string        { return "" }
 func (m *mockHostAPI) CallPlugin(ctx context.Context, pluginName, function string, args json.RawMessage) (json.RawMessage, error) {
 	return nil, nil
@@ -438,6 +453,14 @@ func (h *trackingHostAPI) DBExec(ctx context.Context, query string, args ...any)
 	return 1, nil
 }
 
+func (h *trackingHostAPI) DBBegin(ctx context.Context, dbName string) (string, error) {
+	return "tx-1", nil
+}
+
+func (h *trackingHostAPI) DBCommit(ctx context.Context, txID string) error { return nil }
+
+func (h *trackingHostAPI) DBRollback(ctx context.Context, txID string) error { return nil }
+
 func (h *trackingHostAPI) CacheGet(ctx context.Context, key string) ([]byte, bool, error) {
 	h.cacheGetCalled = true
 	return []byte("cached-value"), true, nil
@@ -453,6 +476,18 @@ func (h *trackingHostAPI) CacheDelete(ctx context.Context, key string) error {
 	return nil
 }
 
+func (h *trackingHostAPI) FilePut(ctx context.Context, path string, content []byte) error {
+	return nil
+}
+
+func (h *trackingHostAPI) FileGet(ctx context.Context, path string) ([]byte, bool, error) {
+	return nil, false, nil
+}
+
+func (h *trackingHostAPI) FileDelete(ctx context.Context, path string) error {
+	return nil
+}
+
 func (h *trackingHostAPI) HTTPRequest(ctx context.Context, method, url string, headers map[string]string, body []byte) (int, []byte, error) {
 	h.httpRequestCalled = true
 	return 200, []byte(`{"ok":true}`), nil
@@ -472,6 +507,10 @@ func (h *trackingHostAPI) ConfigGet(ctx context.Context, key string) (string, er
 	return "config-value", nil
 }
 
+func (h *trackingHostAPI) FeatureEnabled(ctx context.Context, flag string, userID int) (bool, error) {
+	return false, nil
+}
+
 func (h *trackingHostAPI) Translate(ctx context.Context, key string, args ...any) string {
 	h.translateCalled = true
 	return key
@@ -0,0 +1,131 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// widgetCache holds rendered widget HTML per plugin, widget and language,
+// and lets concurrent renders of the same widget share one plugin call.
+type widgetCache struct {
+	mu      sync.Mutex
+	entries map[string]widgetCacheEntry
+	calls   map[string]*widgetCall
+	now     func() time.Time
+}
+
+type widgetCacheEntry struct {
+	html    string
+	expires time.Time
+}
+
+// widgetCall is a plugin call in flight; waiters read html and err once
+// done is closed.
+type widgetCall struct {
+	done chan struct{}
+	html string
+	err  error
+}
+
+func newWidgetCache() *widgetCache {
+	return &widgetCache{
+		entries: make(map[string]widgetCacheEntry),
+		calls:   make(map[string]*widgetCall),
+		now:     time.Now,
+	}
+}
+
+// widgetCacheKey identifies a widget's output. Widgets get no arguments, so
+// the request language is all that varies between callers.
+func widgetCacheKey(ctx context.Context, pluginName, widgetID string) string {
+	lang, _ := ctx.Value(PluginLanguageKey).(string)
+	return pluginName + "\x00" + widgetID + "\x00" + lang
+}
+
+// get returns the cached HTML for key, or joins or starts the call that
+// renders it. render runs at most once per key at a time.
+func (c *widgetCache) get(ctx context.Context, key string, ttl time.Duration, render func() (string, error)) (string, error) {
+	c.mu.Lock()
+	if e, ok := c.entries[key]; ok && c.now().Before(e.expires) {
+		c.mu.Unlock()
+		return e.html, nil
+	}
+	if call, ok := c.calls[key]; ok {
+		c.mu.Unlock()
+		select {
+		case <-call.done:
+			return call.html, call.err
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+	call := &widgetCall{done: make(chan struct{})}
+	c.calls[key] = call
+	c.mu.Unlock()
+
+	call.html, call.err = render()
+
+	c.mu.Lock()
+	// A call invalidated while in flight is no longer in calls; its result
+	// may predate the change and is not kept.
+	if c.calls[key] == call {
+		delete(c.calls, key)
+		if call.err == nil {
+			c.entries[key] = widgetCacheEntry{html: call.html, expires: c.now().Add(ttl)}
+		}
+	}
+	c.mu.Unlock()
+	close(call.done)
+	return call.html, call.err
+}
+
+// invalidate drops everything cached or in flight for a plugin.
+func (c *widgetCache) invalidate(pluginName string) {
+	if c == nil {
+		return
+	}
+	prefix := pluginName + "\x00"
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for key := range c.entries {
+		if strings.HasPrefix(key, prefix) {
+			delete(c.entries, key)
+		}
+	}
+	for key := range c.calls {
+		if strings.HasPrefix(key, prefix) {
+			delete(c.calls, key)
+		}
+	}
+}
+
+// RenderWidget calls a widget's handler and returns the HTML it produced.
+// Output is reused for the widget's CacheTTL, per request language, so many
+// dashboards showing the widget cost one plugin call per interval; failed
+// calls are not cached.
+func (m *Manager) RenderWidget(ctx context.Context, pluginName string, w WidgetSpec) (string, error) {
+	render := func() (string, error) {
+		// Pass an empty JSON object, not nil
+		result, err := m.Call(ctx, pluginName, w.Handler, []byte("{}"))
+		if err != nil {
+			return "", err
+		}
+		var data struct {
+			HTML string `json:"html"`
+		}
+		if err := json.Unmarshal(result, &data); err != nil {
+			return "", fmt.Errorf("invalid widget response: %w", err)
+		}
+		return data.HTML, nil
+	}
+
+	ttl := w.CacheTTL()
+	if ttl <= 0 || m.widgetCache == nil {
+		return render()
+	}
+	return m.widgetCache.get(ctx, widgetCacheKey(ctx, pluginName, w.ID), ttl, render)
+}
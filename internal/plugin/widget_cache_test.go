@@ -0,0 +1,174 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingWidgetPlugin renders a widget that reports how often it was called.
+type countingWidgetPlugin struct {
+	calls   atomic.Int32
+	fail    atomic.Bool
+	release chan struct{} // when set, calls block until it is closed
+}
+
+func (p *countingWidgetPlugin) GKRegister() GKRegistration {
+	return GKRegistration{
+		Name:    "counter",
+		Version: "1.0.0",
+		Widgets: []WidgetSpec{{ID: "count", Handler: "count", Location: "dashboard", Refreshable: true}},
+	}
+}
+
+func (p *countingWidgetPlugin) Init(ctx context.Context, host HostAPI) error { return nil }
+
+func (p *countingWidgetPlugin) Call(ctx context.Context, fn string, args json.RawMessage) (json.RawMessage, error) {
+	if p.release != nil {
+		<-p.release
+	}
+	n := p.calls.Add(1)
+	if p.fail.Load() {
+		return nil, errors.New("backend down")
+	}
+	return json.Marshal(map[string]string{"html": fmt.Sprintf("call %d", n)})
+}
+
+func (p *countingWidgetPlugin) Shutdown(ctx context.Context) error { return nil }
+
+func TestWidgetSpecRefreshInterval(t *testing.T) {
+	tests := []struct {
+		spec    WidgetSpec
+		refresh time.Duration
+		ttl     time.Duration
+	}{
+		{WidgetSpec{}, 0, 0},
+		{WidgetSpec{CacheSec: 30}, 0, 30 * time.Second},
+		{WidgetSpec{Refreshable: true}, 60 * time.Second, 60 * time.Second},
+		{WidgetSpec{Refreshable: true, RefreshSec: 1}, 10 * time.Second, 10 * time.Second},
+		{WidgetSpec{Refreshable: true, RefreshSec: 30, CacheSec: 5}, 30 * time.Second, 5 * time.Second},
+	}
+	for _, tt := range tests {
+		if got := tt.spec.RefreshInterval(); got != tt.refresh {
+			t.Errorf("%+v: RefreshInterval() = %v, want %v", tt.spec, got, tt.refresh)
+		}
+		if got := tt.spec.CacheTTL(); got != tt.ttl {
+			t.Errorf("%+v: CacheTTL() = %v, want %v", tt.spec, got, tt.ttl)
+		}
+	}
+}
+
+func TestManagerRenderWidgetCaches(t *testing.T) {
+	ctx := context.Background()
+	p := &countingWidgetPlugin{}
+	mgr := NewManager(nil)
+	if err := mgr.Register(ctx, p); err != nil {
+		t.Fatalf("register failed: %v", err)
+	}
+	now := time.Now()
+	mgr.widgetCache.now = func() time.Time { return now }
+	spec := p.GKRegister().Widgets[0]
+
+	render := func(ctx context.Context) string {
+		t.Helper()
+		html, err := mgr.RenderWidget(ctx, "counter", spec)
+		if err != nil {
+			t.Fatalf("render failed: %v", err)
+		}
+		return html
+	}
+
+	if got := render(ctx); got != "call 1" {
+		t.Fatalf("first render = %q", got)
+	}
+	if got := render(ctx); got != "call 1" {
+		t.Errorf("expected cached render, got %q", got)
+	}
+	if got := render(context.WithValue(ctx, PluginLanguageKey, "de")); got != "call 2" {
+		t.Errorf("expected a separate render per language, got %q", got)
+	}
+
+	now = now.Add(spec.CacheTTL())
+	if got := render(ctx); got != "call 3" {
+		t.Errorf("expected a new render after the TTL, got %q", got)
+	}
+
+	if err := mgr.Disable("counter"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mgr.RenderWidget(ctx, "counter", spec); err == nil {
+		t.Error("expected disabled plugin to fail instead of serving its cached widget")
+	}
+	if err := mgr.Enable("counter"); err != nil {
+		t.Fatal(err)
+	}
+	if got := render(ctx); got != "call 4" {
+		t.Errorf("expected a new render after re-enabling, got %q", got)
+	}
+
+	now = now.Add(spec.CacheTTL())
+	p.fail.Store(true)
+	if _, err := mgr.RenderWidget(ctx, "counter", spec); err == nil {
+		t.Fatal("expected render error")
+	}
+	p.fail.Store(false)
+	if got := render(ctx); got != "call 6" {
+		t.Errorf("expected failures not to be cached, got %q", got)
+	}
+}
+
+func TestManagerRenderWidgetSharesConcurrentCalls(t *testing.T) {
+	ctx := context.Background()
+	p := &countingWidgetPlugin{release: make(chan struct{})}
+	mgr := NewManager(nil)
+	if err := mgr.Register(ctx, p); err != nil {
+		t.Fatalf("register failed: %v", err)
+	}
+	spec := p.GKRegister().Widgets[0]
+
+	const dashboards = 50
+	var wg sync.WaitGroup
+	results := make([]string, dashboards)
+	for i := range dashboards {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], _ = mgr.RenderWidget(ctx, "counter", spec)
+		}()
+	}
+	// Let the callers pile up behind the first one before it returns
+	time.Sleep(50 * time.Millisecond)
+	close(p.release)
+	wg.Wait()
+
+	if n := p.calls.Load(); n != 1 {
+		t.Errorf("expected one plugin call for %d dashboards, got %d", dashboards, n)
+	}
+	for i, html := range results {
+		if html != "call 1" {
+			t.Errorf("dashboard %d got %q", i, html)
+		}
+	}
+}
+
+func TestManagerRenderWidgetUncached(t *testing.T) {
+	ctx := context.Background()
+	p := &countingWidgetPlugin{}
+	mgr := NewManager(nil)
+	if err := mgr.Register(ctx, p); err != nil {
+		t.Fatalf("register failed: %v", err)
+	}
+	spec := WidgetSpec{ID: "count", Handler: "count"}
+
+	for want := 1; want <= 2; want++ {
+		html, err := mgr.RenderWidget(ctx, "counter", spec)
+		if err != nil || html != fmt.Sprintf("call %d", want) {
+			t.Errorf("render %d = %q, %v", want, html, err)
+		}
+	}
+}
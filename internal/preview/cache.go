@@ -0,0 +1,81 @@
+package preview
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// ErrCacheMiss is returned by Cache.Get for keys that are not cached.
+var ErrCacheMiss = errors.New("preview not cached")
+
+// Cache keeps generated previews. Keys are slash-separated paths such as
+// "attachment/12/small".
+type Cache interface {
+	// Get returns the cached data, or ErrCacheMiss.
+	Get(ctx context.Context, key string) ([]byte, error)
+	Put(ctx context.Context, key string, data []byte) error
+	// Delete removes a key; deleting a key that is not cached is no error.
+	Delete(ctx context.Context, key string) error
+}
+
+// DiskCache keeps previews as files in a directory.
+type DiskCache struct {
+	dir string
+}
+
+// NewDiskCache returns a cache in dir, which is created when the first
+// preview is stored.
+func NewDiskCache(dir string) *DiskCache {
+	return &DiskCache{dir: dir}
+}
+
+func (d *DiskCache) path(key string) string {
+	return filepath.Join(d.dir, filepath.FromSlash(key))
+}
+
+// Get implements Cache.
+func (d *DiskCache) Get(_ context.Context, key string) ([]byte, error) {
+	data, err := os.ReadFile(d.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrCacheMiss
+	}
+	return data, err
+}
+
+// Put implements Cache. The file is written under a temporary name and
+// renamed, so readers never see part of a preview.
+func (d *DiskCache) Put(_ context.Context, key string, data []byte) error {
+	path := d.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return fmt.Errorf("create preview cache directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".preview-*")
+	if err != nil {
+		return fmt.Errorf("create preview file: %w", err)
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return fmt.Errorf("write preview file: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("write preview file: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("store preview file: %w", err)
+	}
+	return nil
+}
+
+// Delete implements Cache.
+func (d *DiskCache) Delete(_ context.Context, key string) error {
+	if err := os.Remove(d.path(key)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
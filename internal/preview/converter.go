@@ -0,0 +1,94 @@
+package preview
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Converter renders the first page of a document as an image, which is
+// then scaled like any image attachment.
+type Converter interface {
+	// Accepts tells whether the converter handles the content type.
+	Accepts(contentType string) bool
+	// FirstPage returns an image of the document's first page in a format
+	// libvips decodes.
+	FirstPage(ctx context.Context, content []byte, contentType string) ([]byte, error)
+}
+
+// acceptsType matches a content type against a list of types.
+func acceptsType(types []string, contentType string) bool {
+	for _, t := range types {
+		if strings.EqualFold(t, contentType) {
+			return true
+		}
+	}
+	return false
+}
+
+// VipsConverter leaves documents to libvips, which loads the first page of
+// a PDF when it was built with PDFium or Poppler.
+type VipsConverter struct {
+	types []string
+}
+
+// NewVipsConverter returns a converter for the content types.
+func NewVipsConverter(types []string) *VipsConverter {
+	return &VipsConverter{types: types}
+}
+
+// Accepts implements Converter.
+func (v *VipsConverter) Accepts(contentType string) bool { return acceptsType(v.types, contentType) }
+
+// FirstPage implements Converter; resizing decodes the first page.
+func (v *VipsConverter) FirstPage(_ context.Context, content []byte, _ string) ([]byte, error) {
+	return content, nil
+}
+
+// CommandConverter runs an external program, such as pdftoppm, that reads
+// a document on stdin and writes an image of its first page to stdout.
+type CommandConverter struct {
+	command string
+	args    []string
+	types   []string
+	timeout time.Duration
+}
+
+// NewCommandConverter returns a converter that runs command with args for
+// the content types. timeout bounds each run.
+func NewCommandConverter(command string, args, types []string, timeout time.Duration) *CommandConverter {
+	return &CommandConverter{command: command, args: args, types: types, timeout: timeout}
+}
+
+// Accepts implements Converter.
+func (c *CommandConverter) Accepts(contentType string) bool { return acceptsType(c.types, contentType) }
+
+// FirstPage implements Converter.
+func (c *CommandConverter) FirstPage(ctx context.Context, content []byte, _ string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, c.command, c.args...) //nolint:gosec // G204: command comes from the configuration
+	cmd.Stdin = bytes.NewReader(content)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	// Children of the program may hold stdout open after it is killed
+	cmd.WaitDelay = time.Second
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("%s timed out after %s", c.command, c.timeout)
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%s: %w: %s", c.command, err, msg)
+		}
+		return nil, fmt.Errorf("%s: %w", c.command, err)
+	}
+	if stdout.Len() == 0 {
+		return nil, fmt.Errorf("%s wrote no image", c.command)
+	}
+	return stdout.Bytes(), nil
+}
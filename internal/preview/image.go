@@ -0,0 +1,47 @@
+package preview
+
+import (
+	"fmt"
+
+	"github.com/davidbyttow/govips/v2/vips"
+)
+
+// resizeImage fits an image into the bounds of the size, without
+// upscaling. Thumbnails are PNG, like the existing ticket thumbnails;
+// large previews are WebP, which keeps them small and transparent.
+func resizeImage(content []byte, size Size) ([]byte, string, error) {
+	img, err := vips.NewImageFromBuffer(content)
+	if err != nil {
+		return nil, "", fmt.Errorf("decode image: %w", err)
+	}
+	defer img.Close()
+
+	maxW, maxH := size.bounds()
+	if scale := fitScale(img.Width(), img.Height(), maxW, maxH); scale < 1 {
+		if err := img.Resize(scale, vips.KernelLanczos3); err != nil {
+			return nil, "", fmt.Errorf("resize image: %w", err)
+		}
+	}
+
+	if size == SizeLarge {
+		data, _, err := img.ExportWebp(&vips.WebpExportParams{Quality: 80, StripMetadata: true})
+		if err != nil {
+			return nil, "", fmt.Errorf("encode preview: %w", err)
+		}
+		return data, "image/webp", nil
+	}
+	data, _, err := img.ExportPng(&vips.PngExportParams{Compression: 6})
+	if err != nil {
+		return nil, "", fmt.Errorf("encode thumbnail: %w", err)
+	}
+	return data, "image/png", nil
+}
+
+// fitScale returns the factor that fits width×height into maxW×maxH while
+// keeping the aspect ratio, at most 1.
+func fitScale(width, height, maxW, maxH int) float64 {
+	if width <= 0 || height <= 0 {
+		return 1
+	}
+	return min(1, float64(maxW)/float64(width), float64(maxH)/float64(height))
+}
@@ -0,0 +1,246 @@
+// Package preview generates thumbnails and inline previews of attachments.
+//
+// Images are scaled down with libvips. Documents such as PDFs are first
+// rendered to an image of their first page by a Converter. Generated
+// previews are kept in a Cache, on disk or in S3, so a ticket shows its
+// attachments without decoding them again. The service configured for the
+// application is set with SetDefault; Default returns nil when previews are
+// off.
+package preview
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/goatkit/goatflow/internal/config"
+)
+
+// ErrUnsupported is returned for attachments that have no preview.
+var ErrUnsupported = errors.New("no preview for this type")
+
+// Size is a preview size.
+type Size string
+
+const (
+	// SizeSmall is the thumbnail shown in attachment lists.
+	SizeSmall Size = "small"
+	// SizeLarge is the inline preview shown when an attachment is opened.
+	SizeLarge Size = "large"
+)
+
+// ParseSize returns the size with the given name; empty is SizeSmall.
+func ParseSize(s string) (Size, error) {
+	switch Size(strings.ToLower(strings.TrimSpace(s))) {
+	case "", SizeSmall:
+		return SizeSmall, nil
+	case SizeLarge:
+		return SizeLarge, nil
+	default:
+		return "", fmt.Errorf("unknown preview size %q, use small or large", s)
+	}
+}
+
+// bounds returns the box a preview of the size is fitted into.
+func (s Size) bounds() (width, height int) {
+	if s == SizeLarge {
+		return 1280, 1280
+	}
+	return 320, 240
+}
+
+// Preview is a generated preview image.
+type Preview struct {
+	Content     []byte
+	ContentType string
+	Cached      bool // Served from the cache
+}
+
+// Loader returns the content and type of the attachment to preview. It is
+// only called when the preview is not cached.
+type Loader func(ctx context.Context) (content []byte, contentType string, err error)
+
+// Service generates and caches previews.
+type Service struct {
+	cache     Cache
+	converter Converter
+	// resize fits an image into the bounds and encodes it; replaced in tests
+	resize func(content []byte, size Size) ([]byte, string, error)
+}
+
+// NewService creates a preview service. A nil converter means documents
+// have no preview.
+func NewService(cache Cache, converter Converter) *Service {
+	return &Service{cache: cache, converter: converter, resize: resizeImage}
+}
+
+// Supports tells whether attachments of the content type have a preview.
+func (s *Service) Supports(contentType string) bool {
+	ct := baseType(contentType)
+	if isImage(ct) {
+		return true
+	}
+	return s.converter != nil && s.converter.Accepts(ct)
+}
+
+// Get returns the preview of an attachment in the given size, from the
+// cache or generated from the content load returns.
+func (s *Service) Get(ctx context.Context, attachmentID int, size Size, load Loader) (*Preview, error) {
+	key := cacheKey(attachmentID, size)
+	if data, err := s.cache.Get(ctx, key); err == nil {
+		return &Preview{Content: data, ContentType: sniffImageType(data), Cached: true}, nil
+	} else if !errors.Is(err, ErrCacheMiss) {
+		log.Printf("preview: reading %s from the cache failed: %v", key, err)
+	}
+
+	content, contentType, err := load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ct := baseType(contentType)
+	switch {
+	case isImage(ct):
+	case s.converter != nil && s.converter.Accepts(ct):
+		if content, err = s.converter.FirstPage(ctx, content, ct); err != nil {
+			return nil, fmt.Errorf("render first page: %w", err)
+		}
+	default:
+		return nil, ErrUnsupported
+	}
+
+	data, outType, err := s.resize(content, size)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.cache.Put(ctx, key, data); err != nil {
+		log.Printf("preview: caching %s failed: %v", key, err)
+	}
+	return &Preview{Content: data, ContentType: outType}, nil
+}
+
+// Invalidate removes the cached previews of an attachment, e.g. when it is
+// deleted.
+func (s *Service) Invalidate(ctx context.Context, attachmentID int) error {
+	for _, size := range []Size{SizeSmall, SizeLarge} {
+		if err := s.cache.Delete(ctx, cacheKey(attachmentID, size)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func cacheKey(attachmentID int, size Size) string {
+	return fmt.Sprintf("attachment/%d/%s", attachmentID, size)
+}
+
+func baseType(contentType string) string {
+	if mt, _, err := mime.ParseMediaType(contentType); err == nil {
+		return mt
+	}
+	return strings.ToLower(strings.TrimSpace(contentType))
+}
+
+// isImage tells whether libvips decodes the type. SVG is left out: it is
+// shown as is and may reference external content when rendered.
+func isImage(ct string) bool {
+	switch ct {
+	case "image/jpeg", "image/jpg", "image/png", "image/gif", "image/webp", "image/bmp",
+		"image/tiff", "image/avif", "image/heic", "image/heif", "image/jxl":
+		return true
+	}
+	return false
+}
+
+// sniffImageType returns the type of a cached preview from its first bytes.
+func sniffImageType(data []byte) string {
+	switch {
+	case len(data) >= 12 && string(data[:4]) == "RIFF" && string(data[8:12]) == "WEBP":
+		return "image/webp"
+	case len(data) >= 3 && data[0] == 0xFF && data[1] == 0xD8 && data[2] == 0xFF:
+		return "image/jpeg"
+	default:
+		return "image/png"
+	}
+}
+
+var defaultService atomic.Pointer[Service]
+
+// SetDefault makes s the service returned by Default. A nil s turns
+// previews off.
+func SetDefault(s *Service) {
+	defaultService.Store(s)
+}
+
+// Default returns the application's preview service, or nil when previews
+// are off.
+func Default() *Service {
+	return defaultService.Load()
+}
+
+// NewFromConfig returns the preview service the storage settings select,
+// or nil when previews are disabled.
+func NewFromConfig(cfg config.StorageConfig) (*Service, error) {
+	pc := cfg.Attachments.Preview
+	if !pc.Enabled {
+		return nil, nil
+	}
+
+	var cache Cache
+	switch strings.ToLower(pc.Cache) {
+	case "", "disk":
+		dir := pc.CachePath
+		if dir == "" {
+			base := cfg.Local.Path
+			if base == "" {
+				base = "./storage"
+			}
+			dir = filepath.Join(base, "previews")
+		}
+		cache = NewDiskCache(dir)
+	case "s3":
+		s3, err := NewS3Cache(S3Options{
+			Bucket:    cfg.S3.Bucket,
+			Region:    cfg.S3.Region,
+			AccessKey: cfg.S3.AccessKey,
+			SecretKey: cfg.S3.SecretKey,
+			Endpoint:  cfg.S3.Endpoint,
+			Prefix:    pc.CachePrefix,
+		})
+		if err != nil {
+			return nil, err
+		}
+		cache = s3
+	default:
+		return nil, fmt.Errorf("unknown preview cache %q", pc.Cache)
+	}
+
+	var converter Converter
+	cc := pc.Converter
+	types := cc.ContentTypes
+	if len(types) == 0 {
+		types = []string{"application/pdf"}
+	}
+	switch strings.ToLower(cc.Driver) {
+	case "":
+	case "vips":
+		converter = NewVipsConverter(types)
+	case "command":
+		if cc.Command == "" {
+			return nil, fmt.Errorf("preview converter command is empty")
+		}
+		timeout := cc.Timeout
+		if timeout <= 0 {
+			timeout = 30 * time.Second
+		}
+		converter = NewCommandConverter(cc.Command, cc.Args, types, timeout)
+	default:
+		return nil, fmt.Errorf("unknown preview converter %q", cc.Driver)
+	}
+	return NewService(cache, converter), nil
+}
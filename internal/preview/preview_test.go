@@ -0,0 +1,155 @@
+package preview
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/config"
+)
+
+func TestService_Get(t *testing.T) {
+	ctx := context.Background()
+	cache := NewDiskCache(t.TempDir())
+	svc := NewService(cache, NewVipsConverter([]string{"application/pdf"}))
+	var resized []Size
+	svc.resize = func(content []byte, size Size) ([]byte, string, error) {
+		resized = append(resized, size)
+		return append([]byte("\x89PNG"), content...), "image/png", nil
+	}
+
+	loads := 0
+	load := func(ct string) Loader {
+		return func(context.Context) ([]byte, string, error) {
+			loads++
+			return []byte("img"), ct, nil
+		}
+	}
+
+	p, err := svc.Get(ctx, 7, SizeSmall, load("image/jpeg"))
+	require.NoError(t, err)
+	assert.False(t, p.Cached)
+	assert.Equal(t, "image/png", p.ContentType)
+
+	p, err = svc.Get(ctx, 7, SizeSmall, load("image/jpeg"))
+	require.NoError(t, err)
+	assert.True(t, p.Cached)
+	assert.Equal(t, "\x89PNGimg", string(p.Content))
+	assert.Equal(t, 1, loads, "cached previews do not load the attachment")
+
+	_, err = svc.Get(ctx, 7, SizeLarge, load("image/jpeg"))
+	require.NoError(t, err)
+	assert.Equal(t, []Size{SizeSmall, SizeLarge}, resized)
+
+	_, err = svc.Get(ctx, 8, SizeSmall, load("application/PDF; name=a.pdf"))
+	require.NoError(t, err, "PDFs go through the converter")
+	_, err = svc.Get(ctx, 9, SizeSmall, load("text/plain"))
+	assert.ErrorIs(t, err, ErrUnsupported)
+	_, err = svc.Get(ctx, 10, SizeSmall, load("image/svg+xml"))
+	assert.ErrorIs(t, err, ErrUnsupported)
+
+	boom := errors.New("boom")
+	_, err = svc.Get(ctx, 11, SizeSmall, func(context.Context) ([]byte, string, error) { return nil, "", boom })
+	assert.ErrorIs(t, err, boom)
+
+	require.NoError(t, svc.Invalidate(ctx, 7))
+	_, err = cache.Get(ctx, cacheKey(7, SizeSmall))
+	assert.ErrorIs(t, err, ErrCacheMiss)
+	require.NoError(t, svc.Invalidate(ctx, 7), "invalidating twice is fine")
+
+	assert.True(t, svc.Supports("image/png"))
+	assert.True(t, svc.Supports("application/pdf"))
+	assert.False(t, NewService(cache, nil).Supports("application/pdf"))
+}
+
+func TestParseSize(t *testing.T) {
+	for in, want := range map[string]Size{"": SizeSmall, "small": SizeSmall, "LARGE": SizeLarge} {
+		got, err := ParseSize(in)
+		require.NoError(t, err)
+		assert.Equal(t, want, got)
+	}
+	_, err := ParseSize("huge")
+	assert.Error(t, err)
+}
+
+func TestFitScale(t *testing.T) {
+	assert.Equal(t, 1.0, fitScale(100, 100, 320, 240), "small images are not upscaled")
+	assert.Equal(t, 0.5, fitScale(640, 200, 320, 240))
+	assert.Equal(t, 0.25, fitScale(100, 960, 320, 240))
+	assert.Equal(t, 1.0, fitScale(0, 0, 320, 240))
+}
+
+func TestDiskCache(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	cache := NewDiskCache(dir)
+
+	_, err := cache.Get(ctx, "attachment/1/small")
+	assert.ErrorIs(t, err, ErrCacheMiss)
+	require.NoError(t, cache.Put(ctx, "attachment/1/small", []byte("one")))
+	require.NoError(t, cache.Put(ctx, "attachment/1/small", []byte("two")))
+	data, err := cache.Get(ctx, "attachment/1/small")
+	require.NoError(t, err)
+	assert.Equal(t, "two", string(data))
+
+	entries, err := os.ReadDir(filepath.Join(dir, "attachment", "1"))
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "no temporary files are left")
+
+	require.NoError(t, cache.Delete(ctx, "attachment/1/small"))
+	require.NoError(t, cache.Delete(ctx, "attachment/1/small"))
+}
+
+func TestCommandConverter(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+	ctx := context.Background()
+
+	conv := NewCommandConverter("sh", []string{"-c", "printf page1; cat >/dev/null"}, []string{"application/pdf"}, 5*time.Second)
+	assert.True(t, conv.Accepts("application/pdf"))
+	assert.False(t, conv.Accepts("image/png"))
+	out, err := conv.FirstPage(ctx, []byte("%PDF-1.4"), "application/pdf")
+	require.NoError(t, err)
+	assert.Equal(t, "page1", string(out))
+
+	_, err = NewCommandConverter("sh", []string{"-c", "echo broken >&2; exit 1"}, nil, 5*time.Second).FirstPage(ctx, nil, "")
+	assert.ErrorContains(t, err, "broken")
+	_, err = NewCommandConverter("sh", []string{"-c", "cat >/dev/null"}, nil, 5*time.Second).FirstPage(ctx, nil, "")
+	assert.ErrorContains(t, err, "wrote no image")
+	_, err = NewCommandConverter("sh", []string{"-c", "sleep 5"}, nil, 50*time.Millisecond).FirstPage(ctx, nil, "")
+	assert.ErrorContains(t, err, "timed out")
+}
+
+func TestNewFromConfig(t *testing.T) {
+	var cfg config.StorageConfig
+	svc, err := NewFromConfig(cfg)
+	require.NoError(t, err)
+	assert.Nil(t, svc, "previews are off unless enabled")
+
+	cfg.Attachments.Preview.Enabled = true
+	cfg.Local.Path = t.TempDir()
+	svc, err = NewFromConfig(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(cfg.Local.Path, "previews"), svc.cache.(*DiskCache).dir)
+	assert.Nil(t, svc.converter)
+
+	cfg.Attachments.Preview.Converter.Driver = "command"
+	_, err = NewFromConfig(cfg)
+	assert.ErrorContains(t, err, "command is empty")
+	cfg.Attachments.Preview.Converter.Driver = "unoconv"
+	_, err = NewFromConfig(cfg)
+	assert.ErrorContains(t, err, "unknown preview converter")
+
+	cfg.Attachments.Preview.Converter.Driver = ""
+	cfg.Attachments.Preview.Cache = "s3"
+	_, err = NewFromConfig(cfg)
+	assert.ErrorContains(t, err, "bucket is empty")
+}
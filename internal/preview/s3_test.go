@@ -0,0 +1,92 @@
+package preview
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeS3 stores objects in memory and records the signature headers.
+type fakeS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	auth    []string
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.auth = append(f.auth, r.Header.Get("Authorization"))
+	if r.Header.Get("x-amz-date") == "" || r.Header.Get("x-amz-content-sha256") == "" {
+		http.Error(w, "unsigned", http.StatusForbidden)
+		return
+	}
+	switch r.Method {
+	case http.MethodPut:
+		body, _ := io.ReadAll(r.Body)
+		if sha256Hex(body) != r.Header.Get("x-amz-content-sha256") {
+			http.Error(w, "XAmzContentSHA256Mismatch", http.StatusBadRequest)
+			return
+		}
+		f.objects[r.URL.Path] = body
+	case http.MethodGet:
+		data, ok := f.objects[r.URL.Path]
+		if !ok {
+			http.Error(w, "NoSuchKey", http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write(data)
+	case http.MethodDelete:
+		delete(f.objects, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func TestS3Cache(t *testing.T) {
+	fake := &fakeS3{objects: map[string][]byte{}}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	cache, err := NewS3Cache(S3Options{
+		Bucket:    "goatflow",
+		AccessKey: "AKIDEXAMPLE",
+		SecretKey: "secret",
+		Endpoint:  srv.URL,
+		Prefix:    "previews/",
+	})
+	require.NoError(t, err)
+	cache.now = func() time.Time { return time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC) }
+	ctx := context.Background()
+
+	_, err = cache.Get(ctx, "attachment/3/small")
+	assert.ErrorIs(t, err, ErrCacheMiss)
+	require.NoError(t, cache.Put(ctx, "attachment/3/small", []byte("\x89PNG")))
+	assert.Contains(t, fake.objects, "/goatflow/previews/attachment/3/small", "path-style addressing for custom endpoints")
+	data, err := cache.Get(ctx, "attachment/3/small")
+	require.NoError(t, err)
+	assert.Equal(t, "\x89PNG", string(data))
+	require.NoError(t, cache.Delete(ctx, "attachment/3/small"))
+	assert.Empty(t, fake.objects)
+
+	auth := fake.auth[1]
+	assert.True(t, strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20261016/us-east-1/s3/aws4_request, "), auth)
+	assert.Contains(t, auth, "SignedHeaders=host;x-amz-content-sha256;x-amz-date")
+	assert.Regexp(t, `Signature=[0-9a-f]{64}$`, auth)
+}
+
+func TestNewS3Cache_AWSEndpoint(t *testing.T) {
+	cache, err := NewS3Cache(S3Options{Bucket: "files", Region: "eu-central-1", AccessKey: "a", SecretKey: "b"})
+	require.NoError(t, err)
+	assert.Equal(t, "https://files.s3.eu-central-1.amazonaws.com/", cache.base.String())
+
+	_, err = NewS3Cache(S3Options{Bucket: "files"})
+	assert.ErrorContains(t, err, "access_key and secret_key are required")
+}
@@ -0,0 +1,201 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/models"
+)
+
+// AgentStatsRepository reads the ticket history and accounted time that
+// agent performance statistics are computed from.
+type AgentStatsRepository struct {
+	db *sql.DB
+}
+
+// NewAgentStatsRepository creates a new agent statistics repository.
+func NewAgentStatsRepository(db *sql.DB) *AgentStatsRepository {
+	return &AgentStatsRepository{db: db}
+}
+
+// QueueTeam is a queue with the agent group, or team, that owns it.
+type QueueTeam struct {
+	QueueName string
+	TeamID    int
+	TeamName  string
+}
+
+// Resolutions returns the tickets that moved into a closed state during
+// [from, to), once per move, credited to the owner at that moment. The
+// whole history of those tickets is read, so that a resolution counts as
+// reopened when the ticket later left its closed state, and as meeting its
+// SLA when its solution time had not escalated before it was closed.
+// Tickets have a solution time target when their SLA, or their queue
+// without an SLA, sets one.
+func (r *AgentStatsRepository) Resolutions(ctx context.Context, from, to time.Time) ([]models.AgentResolution, error) {
+	rows, err := r.db.QueryContext(ctx, database.ConvertPlaceholders(`
+		SELECT th.ticket_id, th.owner_id, th.queue_id, th.create_time,
+		       COALESCE(st.name, ''), COALESCE(tht.name, ''),
+		       CASE WHEN sla.id IS NOT NULL THEN COALESCE(sla.solution_time, 0)
+		            ELSE COALESCE(q.solution_time, 0) END
+		FROM ticket_history th
+		JOIN ticket t ON t.id = th.ticket_id
+		LEFT JOIN ticket_state s ON s.id = th.state_id
+		LEFT JOIN ticket_state_type st ON st.id = s.type_id
+		LEFT JOIN ticket_history_type tht ON tht.id = th.history_type_id
+		LEFT JOIN sla ON sla.id = t.sla_id
+		LEFT JOIN queue q ON q.id = t.queue_id
+		WHERE th.ticket_id IN (
+			SELECT h.ticket_id FROM ticket_history h
+			JOIN ticket_state hs ON hs.id = h.state_id
+			JOIN ticket_state_type hst ON hst.id = hs.type_id
+			WHERE hst.name = 'closed' AND h.create_time >= ? AND h.create_time < ?)
+		ORDER BY th.ticket_id, th.create_time, th.id`), from, to)
+	if err != nil {
+		return nil, fmt.Errorf("query ticket history: %w", err)
+	}
+	defer rows.Close()
+
+	resolutions := make([]models.AgentResolution, 0)
+	var ticketID int64 = -1
+	var closed, breached bool
+	last := -1
+	for rows.Next() {
+		var id int64
+		var ownerID, queueID, solutionTime int
+		var at time.Time
+		var stateType, historyType string
+		if err := rows.Scan(&id, &ownerID, &queueID, &at, &stateType, &historyType, &solutionTime); err != nil {
+			return nil, fmt.Errorf("scan ticket history: %w", err)
+		}
+		if id != ticketID {
+			ticketID, closed, breached, last = id, false, false, -1
+		}
+		if historyType == "EscalationSolutionTimeStart" {
+			breached = true
+		}
+		isClosed := stateType == "closed"
+		switch {
+		case isClosed && !closed:
+			last = -1
+			if !at.Before(from) && at.Before(to) {
+				resolutions = append(resolutions, models.AgentResolution{
+					TicketID: id,
+					AgentID:  ownerID,
+					QueueID:  queueID,
+					Time:     at,
+					HasSLA:   solutionTime > 0,
+					SLAMet:   solutionTime > 0 && !breached,
+				})
+				last = len(resolutions) - 1
+			}
+		case !isClosed && closed && last >= 0:
+			resolutions[last].Reopened = true
+			last = -1
+		}
+		closed = isClosed
+	}
+	return resolutions, rows.Err()
+}
+
+// TimeEntries returns the time accounted during [from, to), by the agent
+// who accounted it and the ticket's current queue.
+func (r *AgentStatsRepository) TimeEntries(ctx context.Context, from, to time.Time) ([]models.AgentTimeEntry, error) {
+	rows, err := r.db.QueryContext(ctx, database.ConvertPlaceholders(`
+		SELECT ta.ticket_id, ta.create_by, t.queue_id, ta.time_unit
+		FROM time_accounting ta
+		JOIN ticket t ON t.id = ta.ticket_id
+		WHERE ta.create_time >= ? AND ta.create_time < ?`), from, to)
+	if err != nil {
+		return nil, fmt.Errorf("query time accounting: %w", err)
+	}
+	defer rows.Close()
+
+	entries := make([]models.AgentTimeEntry, 0)
+	for rows.Next() {
+		var e models.AgentTimeEntry
+		if err := rows.Scan(&e.TicketID, &e.AgentID, &e.QueueID, &e.Minutes); err != nil {
+			return nil, fmt.Errorf("scan time accounting: %w", err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// AgentName is the login and full name of an agent.
+type AgentName struct {
+	Login string
+	Name  string
+}
+
+// Agents returns the names of agents by user ID.
+func (r *AgentStatsRepository) Agents(ctx context.Context, ids []int) (map[int]AgentName, error) {
+	agents := make(map[int]AgentName, len(ids))
+	if len(ids) == 0 {
+		return agents, nil
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(ids)), ",")
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	rows, err := r.db.QueryContext(ctx, database.ConvertPlaceholders(`
+		SELECT id, login, COALESCE(first_name, ''), COALESCE(last_name, '')
+		FROM users WHERE id IN (`+placeholders+`)`), args...)
+	if err != nil {
+		return nil, fmt.Errorf("query agents: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int
+		var login, first, last string
+		if err := rows.Scan(&id, &login, &first, &last); err != nil {
+			return nil, fmt.Errorf("scan agent: %w", err)
+		}
+		agents[id] = AgentName{Login: login, Name: strings.TrimSpace(first + " " + last)}
+	}
+	return agents, rows.Err()
+}
+
+// QueueTeams returns every queue with the group that owns it, by queue ID.
+func (r *AgentStatsRepository) QueueTeams(ctx context.Context) (map[int]QueueTeam, error) {
+	rows, err := r.db.QueryContext(ctx, database.ConvertPlaceholders(`
+		SELECT q.id, q.name, q.group_id, COALESCE(g.name, '')
+		FROM queue q
+		LEFT JOIN `+"`groups`"+` g ON g.id = q.group_id`))
+	if err != nil {
+		return nil, fmt.Errorf("query queues: %w", err)
+	}
+	defer rows.Close()
+
+	queues := make(map[int]QueueTeam)
+	for rows.Next() {
+		var id int
+		var qt QueueTeam
+		if err := rows.Scan(&id, &qt.QueueName, &qt.TeamID, &qt.TeamName); err != nil {
+			return nil, fmt.Errorf("scan queue: %w", err)
+		}
+		queues[id] = qt
+	}
+	return queues, rows.Err()
+}
+
+// GroupID returns the ID of the valid group with the given name, or 0 if
+// there is none.
+func (r *AgentStatsRepository) GroupID(ctx context.Context, name string) (int, error) {
+	var id int
+	err := r.db.QueryRowContext(ctx, database.ConvertPlaceholders(
+		"SELECT id FROM `groups` WHERE name = ? AND valid_id = 1"), name).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("get group: %w", err)
+	}
+	return id, nil
+}
@@ -32,11 +32,20 @@ func (r *APITokenRepository) Create(ctx context.Context, token *models.APIToken)
 		scopesJSON = sql.NullString{String: string(data), Valid: true}
 	}
 
+	var allowedIPsJSON sql.NullString
+	if len(token.AllowedIPs) > 0 {
+		data, err := json.Marshal(token.AllowedIPs)
+		if err != nil {
+			return 0, fmt.Errorf("marshal allowed ips: %w", err)
+		}
+		allowedIPsJSON = sql.NullString{String: string(data), Valid: true}
+	}
+
 	query := database.ConvertPlaceholders(`
 		INSERT INTO user_api_tokens (
-			user_id, user_type, name, prefix, token_hash, scopes,
+			user_id, user_type, name, prefix, token_hash, scopes, allowed_ips,
 			expires_at, rate_limit, created_at, created_by
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`)
 
 	result, err := r.db.ExecContext(ctx, query,
@@ -46,6 +55,7 @@ func (r *APITokenRepository) Create(ctx context.Context, token *models.APIToken)
 		token.Prefix,
 		token.TokenHash,
 		scopesJSON,
+		allowedIPsJSON,
 		token.ExpiresAt,
 		token.RateLimit,
 		token.CreatedAt,
@@ -61,7 +71,7 @@ func (r *APITokenRepository) Create(ctx context.Context, token *models.APIToken)
 // GetByPrefix retrieves tokens matching a prefix (for verification)
 func (r *APITokenRepository) GetByPrefix(ctx context.Context, prefix string) ([]*models.APIToken, error) {
 	query := database.ConvertPlaceholders(`
-		SELECT id, user_id, user_type, name, prefix, token_hash, scopes,
+		SELECT id, user_id, user_type, name, prefix, token_hash, scopes, allowed_ips,
 			   expires_at, last_used_at, last_used_ip, rate_limit,
 			   created_at, created_by, revoked_at, revoked_by
 		FROM user_api_tokens
@@ -89,7 +99,7 @@ func (r *APITokenRepository) GetByPrefix(ctx context.Context, prefix string) ([]
 // GetByID retrieves a token by ID
 func (r *APITokenRepository) GetByID(ctx context.Context, id int64) (*models.APIToken, error) {
 	query := database.ConvertPlaceholders(`
-		SELECT id, user_id, user_type, name, prefix, token_hash, scopes,
+		SELECT id, user_id, user_type, name, prefix, token_hash, scopes, allowed_ips,
 			   expires_at, last_used_at, last_used_ip, rate_limit,
 			   created_at, created_by, revoked_at, revoked_by
 		FROM user_api_tokens
@@ -103,7 +113,7 @@ func (r *APITokenRepository) GetByID(ctx context.Context, id int64) (*models.API
 // ListByUser retrieves all tokens for a user
 func (r *APITokenRepository) ListByUser(ctx context.Context, userID int, userType models.APITokenUserType) ([]*models.APIToken, error) {
 	query := database.ConvertPlaceholders(`
-		SELECT id, user_id, user_type, name, prefix, token_hash, scopes,
+		SELECT id, user_id, user_type, name, prefix, token_hash, scopes, allowed_ips,
 			   expires_at, last_used_at, last_used_ip, rate_limit,
 			   created_at, created_by, revoked_at, revoked_by
 		FROM user_api_tokens
@@ -129,6 +139,42 @@ func (r *APITokenRepository) ListByUser(ctx context.Context, userID int, userTyp
 	return tokens, rows.Err()
 }
 
+// UserIDByLogin returns the ID of the agent or customer user with the
+// login, or 0 if there is none.
+func (r *APITokenRepository) UserIDByLogin(ctx context.Context, login string, userType models.APITokenUserType) (int, error) {
+	query := `SELECT id FROM users WHERE login = ?`
+	if userType == models.APITokenUserCustomer {
+		query = `SELECT id FROM customer_user WHERE login = ?`
+	}
+	var id int
+	err := r.db.QueryRowContext(ctx, database.ConvertPlaceholders(query), login).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("query user: %w", err)
+	}
+	return id, nil
+}
+
+// UserLogin returns the login of a token's owner, or "" if the user no
+// longer exists.
+func (r *APITokenRepository) UserLogin(ctx context.Context, userID int, userType models.APITokenUserType) (string, error) {
+	query := `SELECT login FROM users WHERE id = ?`
+	if userType == models.APITokenUserCustomer {
+		query = `SELECT login FROM customer_user WHERE id = ?`
+	}
+	var login string
+	err := r.db.QueryRowContext(ctx, database.ConvertPlaceholders(query), userID).Scan(&login)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("query user: %w", err)
+	}
+	return login, nil
+}
+
 // UpdateLastUsed updates the last used timestamp and IP
 func (r *APITokenRepository) UpdateLastUsed(ctx context.Context, id int64, ip string) error {
 	query := database.ConvertPlaceholders(`
@@ -167,7 +213,7 @@ func (r *APITokenRepository) ListAll(ctx context.Context, includeRevoked bool) (
 	var query string
 	if includeRevoked {
 		query = `
-			SELECT id, user_id, user_type, name, prefix, token_hash, scopes,
+			SELECT id, user_id, user_type, name, prefix, token_hash, scopes, allowed_ips,
 				   expires_at, last_used_at, last_used_ip, rate_limit,
 				   created_at, created_by, revoked_at, revoked_by
 			FROM user_api_tokens
@@ -175,7 +221,7 @@ func (r *APITokenRepository) ListAll(ctx context.Context, includeRevoked bool) (
 		`
 	} else {
 		query = `
-			SELECT id, user_id, user_type, name, prefix, token_hash, scopes,
+			SELECT id, user_id, user_type, name, prefix, token_hash, scopes, allowed_ips,
 				   expires_at, last_used_at, last_used_ip, rate_limit,
 				   created_at, created_by, revoked_at, revoked_by
 			FROM user_api_tokens
@@ -213,6 +259,7 @@ func (r *APITokenRepository) scanToken(rows *sql.Rows) (*models.APIToken, error)
 		&token.Prefix,
 		&token.TokenHash,
 		&token.ScopesJSON,
+		&token.AllowedIPsJSON,
 		&token.ExpiresAt,
 		&token.LastUsedAt,
 		&token.LastUsedIP,
@@ -233,6 +280,13 @@ func (r *APITokenRepository) scanToken(rows *sql.Rows) (*models.APIToken, error)
 		}
 	}
 
+	// Parse allowed IPs JSON
+	if token.AllowedIPsJSON.Valid && token.AllowedIPsJSON.String != "" {
+		if err := json.Unmarshal([]byte(token.AllowedIPsJSON.String), &token.AllowedIPs); err != nil {
+			return nil, fmt.Errorf("parse allowed ips: %w", err)
+		}
+	}
+
 	return &token, nil
 }
 
@@ -247,6 +301,7 @@ func (r *APITokenRepository) scanTokenRow(row *sql.Row) (*models.APIToken, error
 		&token.Prefix,
 		&token.TokenHash,
 		&token.ScopesJSON,
+		&token.AllowedIPsJSON,
 		&token.ExpiresAt,
 		&token.LastUsedAt,
 		&token.LastUsedIP,
@@ -270,5 +325,12 @@ func (r *APITokenRepository) scanTokenRow(row *sql.Row) (*models.APIToken, error
 		}
 	}
 
+	// Parse allowed IPs JSON
+	if token.AllowedIPsJSON.Valid && token.AllowedIPsJSON.String != "" {
+		if err := json.Unmarshal([]byte(token.AllowedIPsJSON.String), &token.AllowedIPs); err != nil {
+			return nil, fmt.Errorf("parse allowed ips: %w", err)
+		}
+	}
+
 	return &token, nil
 }
@@ -0,0 +1,416 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/models"
+)
+
+const approvalRuleSelect = `
+	SELECT id, name, queue_id, action, approver_group_id, COALESCE(comments, ''), valid_id,
+	       create_time, create_by, change_time, change_by
+	FROM ticket_approval_rule`
+
+const approvalRequestSelect = `
+	SELECT r.id, r.rule_id, COALESCE(ar.name, ''), ar.approver_group_id, r.ticket_id, COALESCE(t.tn, ''),
+	       COALESCE(t.queue_id, 0), r.action, r.target_id, COALESCE(r.reason, ''), r.status,
+	       r.requested_by, r.requested_time, r.decided_by, r.decided_time, COALESCE(r.decision_comment, '')
+	FROM ticket_approval_request r
+	JOIN ticket_approval_rule ar ON ar.id = r.rule_id
+	LEFT JOIN ticket t ON t.id = r.ticket_id`
+
+// ErrApprovalAlreadyDecided is returned when an approval request is no
+// longer pending.
+var ErrApprovalAlreadyDecided = errors.New("approval request is no longer pending")
+
+// ApprovalRepository handles database operations for ticket approval rules
+// and requests.
+type ApprovalRepository struct {
+	db *sql.DB
+}
+
+// NewApprovalRepository creates a new approval repository.
+func NewApprovalRepository(db *sql.DB) *ApprovalRepository {
+	return &ApprovalRepository{db: db}
+}
+
+// ListRules returns all valid approval rules ordered by name.
+func (r *ApprovalRepository) ListRules(ctx context.Context) ([]models.ApprovalRule, error) {
+	rows, err := r.db.QueryContext(ctx, database.ConvertPlaceholders(approvalRuleSelect+" WHERE valid_id = 1 ORDER BY name"))
+	if err != nil {
+		return nil, fmt.Errorf("query approval rules: %w", err)
+	}
+	defer rows.Close()
+
+	rules := make([]models.ApprovalRule, 0)
+	for rows.Next() {
+		rule, err := scanApprovalRule(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan approval rule: %w", err)
+		}
+		rules = append(rules, *rule)
+	}
+	return rules, rows.Err()
+}
+
+// GetRule returns an approval rule by ID, or nil if it does not exist.
+func (r *ApprovalRepository) GetRule(ctx context.Context, id int) (*models.ApprovalRule, error) {
+	row := r.db.QueryRowContext(ctx, database.ConvertPlaceholders(approvalRuleSelect+" WHERE id = ? AND valid_id = 1"), id)
+	rule, err := scanApprovalRule(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get approval rule: %w", err)
+	}
+	return rule, nil
+}
+
+// MatchRule returns the rule guarding action on tickets in queueID, or nil.
+// A rule for the queue wins over a rule for every queue.
+func (r *ApprovalRepository) MatchRule(ctx context.Context, queueID int, action models.ApprovalAction) (*models.ApprovalRule, error) {
+	row := r.db.QueryRowContext(ctx, database.ConvertPlaceholders(approvalRuleSelect+`
+		WHERE valid_id = 1 AND action = ? AND (queue_id = ? OR queue_id IS NULL)
+		ORDER BY CASE WHEN queue_id IS NULL THEN 1 ELSE 0 END, id`), string(action), queueID)
+	rule, err := scanApprovalRule(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("match approval rule: %w", err)
+	}
+	return rule, nil
+}
+
+// RuleNameExists reports whether another valid rule already uses the name.
+func (r *ApprovalRepository) RuleNameExists(ctx context.Context, name string, excludeID int) (bool, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx, database.ConvertPlaceholders(
+		"SELECT COUNT(*) FROM ticket_approval_rule WHERE name = ? AND id <> ? AND valid_id = 1",
+	), name, excludeID).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("check approval rule name: %w", err)
+	}
+	return count > 0, nil
+}
+
+// CreateRule inserts an approval rule and returns its ID.
+func (r *ApprovalRepository) CreateRule(ctx context.Context, rule *models.ApprovalRule) (int, error) {
+	now := time.Now()
+	query := database.ConvertPlaceholders(`
+		INSERT INTO ticket_approval_rule (name, queue_id, action, approver_group_id, comments, valid_id,
+			create_time, create_by, change_time, change_by)
+		VALUES (?, ?, ?, ?, ?, 1, ?, ?, ?, ?)
+		RETURNING id`)
+	id, err := database.GetAdapter().InsertWithReturning(r.db, query,
+		rule.Name, rule.QueueID, string(rule.Action), rule.ApproverGroupID, rule.Comments,
+		now, rule.CreateBy, now, rule.CreateBy)
+	if err != nil {
+		return 0, fmt.Errorf("insert approval rule: %w", err)
+	}
+	return int(id), nil
+}
+
+// UpdateRule stores changes to an approval rule.
+func (r *ApprovalRepository) UpdateRule(ctx context.Context, rule *models.ApprovalRule, userID int) error {
+	result, err := r.db.ExecContext(ctx, database.ConvertPlaceholders(`
+		UPDATE ticket_approval_rule
+		SET name = ?, queue_id = ?, action = ?, approver_group_id = ?, comments = ?, change_time = ?, change_by = ?
+		WHERE id = ? AND valid_id = 1
+	`), rule.Name, rule.QueueID, string(rule.Action), rule.ApproverGroupID, rule.Comments, time.Now(), userID, rule.ID)
+	if err != nil {
+		return fmt.Errorf("update approval rule: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// InvalidateRule soft-deletes an approval rule by marking it invalid.
+func (r *ApprovalRepository) InvalidateRule(ctx context.Context, id int, userID int) error {
+	result, err := r.db.ExecContext(ctx, database.ConvertPlaceholders(`
+		UPDATE ticket_approval_rule SET valid_id = 2, change_time = ?, change_by = ?
+		WHERE id = ? AND valid_id = 1
+	`), time.Now(), userID, id)
+	if err != nil {
+		return fmt.Errorf("invalidate approval rule: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// GroupExists reports whether a valid group with the ID exists.
+func (r *ApprovalRepository) GroupExists(ctx context.Context, groupID int) (bool, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx, database.ConvertPlaceholders(
+		"SELECT COUNT(*) FROM groups WHERE id = ? AND valid_id = 1",
+	), groupID).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("check approver group: %w", err)
+	}
+	return count > 0, nil
+}
+
+// IsGroupMember reports whether the agent belongs to the group.
+func (r *ApprovalRepository) IsGroupMember(ctx context.Context, groupID, userID int) (bool, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx, database.ConvertPlaceholders(`
+		SELECT COUNT(*)
+		FROM group_user gu
+		JOIN groups g ON g.id = gu.group_id
+		WHERE gu.group_id = ? AND gu.user_id = ? AND g.valid_id = 1
+	`), groupID, userID).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("check approver membership: %w", err)
+	}
+	return count > 0, nil
+}
+
+// GroupMemberEmails returns the addresses of valid agents in the group.
+// Agent logins are email addresses; logins without an @ are skipped.
+func (r *ApprovalRepository) GroupMemberEmails(ctx context.Context, groupID int) ([]string, error) {
+	rows, err := r.db.QueryContext(ctx, database.ConvertPlaceholders(`
+		SELECT DISTINCT u.login
+		FROM users u
+		JOIN group_user gu ON gu.user_id = u.id
+		JOIN groups g ON g.id = gu.group_id
+		WHERE u.valid_id = 1 AND g.valid_id = 1 AND gu.group_id = ?
+		ORDER BY u.login
+	`), groupID)
+	if err != nil {
+		return nil, fmt.Errorf("query approvers: %w", err)
+	}
+	defer rows.Close()
+
+	emails := make([]string, 0)
+	for rows.Next() {
+		var login string
+		if err := rows.Scan(&login); err != nil {
+			return nil, fmt.Errorf("scan approver: %w", err)
+		}
+		if strings.Contains(login, "@") {
+			emails = append(emails, login)
+		}
+	}
+	return emails, rows.Err()
+}
+
+// UserEmail returns the agent's login when it is an email address.
+func (r *ApprovalRepository) UserEmail(ctx context.Context, userID int) (string, error) {
+	var login string
+	err := r.db.QueryRowContext(ctx, database.ConvertPlaceholders(
+		"SELECT login FROM users WHERE id = ? AND valid_id = 1",
+	), userID).Scan(&login)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("load agent %d: %w", userID, err)
+	}
+	if !strings.Contains(login, "@") {
+		return "", nil
+	}
+	return login, nil
+}
+
+// TicketQueue returns the ticket's number and queue; ok is false when the
+// ticket does not exist.
+func (r *ApprovalRepository) TicketQueue(ctx context.Context, ticketID int) (tn string, queueID int, ok bool, err error) {
+	err = r.db.QueryRowContext(ctx, database.ConvertPlaceholders(
+		"SELECT tn, queue_id FROM ticket WHERE id = ?",
+	), ticketID).Scan(&tn, &queueID)
+	if err == sql.ErrNoRows {
+		return "", 0, false, nil
+	}
+	if err != nil {
+		return "", 0, false, fmt.Errorf("load ticket %d: %w", ticketID, err)
+	}
+	return tn, queueID, true, nil
+}
+
+// StateClosesTicket reports whether the state is of type closed.
+func (r *ApprovalRepository) StateClosesTicket(ctx context.Context, stateID int) (bool, error) {
+	var typeName string
+	err := r.db.QueryRowContext(ctx, database.ConvertPlaceholders(`
+		SELECT st.name
+		FROM ticket_state s
+		JOIN ticket_state_type st ON st.id = s.type_id
+		WHERE s.id = ?`), stateID).Scan(&typeName)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("load state %d: %w", stateID, err)
+	}
+	return typeName == "closed", nil
+}
+
+// CreateRequest inserts a pending approval request and returns its ID.
+func (r *ApprovalRepository) CreateRequest(ctx context.Context, req *models.ApprovalRequest) (int, error) {
+	query := database.ConvertPlaceholders(`
+		INSERT INTO ticket_approval_request (rule_id, ticket_id, action, target_id, reason, status,
+			requested_by, requested_time)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id`)
+	id, err := database.GetAdapter().InsertWithReturning(r.db, query,
+		req.RuleID, req.TicketID, string(req.Action), req.TargetID, req.Reason,
+		string(models.ApprovalStatusPending), req.RequestedBy, req.RequestedTime)
+	if err != nil {
+		return 0, fmt.Errorf("insert approval request: %w", err)
+	}
+	return int(id), nil
+}
+
+// GetRequest returns an approval request by ID, or nil if it does not exist.
+func (r *ApprovalRepository) GetRequest(ctx context.Context, id int) (*models.ApprovalRequest, error) {
+	row := r.db.QueryRowContext(ctx, database.ConvertPlaceholders(approvalRequestSelect+" WHERE r.id = ?"), id)
+	req, err := scanApprovalRequest(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get approval request: %w", err)
+	}
+	return req, nil
+}
+
+// PendingRequest returns the pending request for the action on the ticket,
+// or nil.
+func (r *ApprovalRepository) PendingRequest(ctx context.Context, ticketID int, action models.ApprovalAction) (*models.ApprovalRequest, error) {
+	row := r.db.QueryRowContext(ctx, database.ConvertPlaceholders(approvalRequestSelect+`
+		WHERE r.ticket_id = ? AND r.action = ? AND r.status = ?
+		ORDER BY r.id`), ticketID, string(action), string(models.ApprovalStatusPending))
+	req, err := scanApprovalRequest(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get pending approval request: %w", err)
+	}
+	return req, nil
+}
+
+// ListRequests returns the approval requests matching the filter, newest first.
+func (r *ApprovalRepository) ListRequests(ctx context.Context, filter models.ApprovalRequestFilter) ([]models.ApprovalRequest, error) {
+	var where []string
+	var args []interface{}
+	if filter.Status != "" {
+		where = append(where, "r.status = ?")
+		args = append(args, string(filter.Status))
+	}
+	if filter.TicketID > 0 {
+		where = append(where, "r.ticket_id = ?")
+		args = append(args, filter.TicketID)
+	}
+	if filter.VisibleTo > 0 {
+		where = append(where, `(r.requested_by = ? OR ar.approver_group_id IN (
+			SELECT gu.group_id FROM group_user gu WHERE gu.user_id = ?))`)
+		args = append(args, filter.VisibleTo, filter.VisibleTo)
+	}
+	query := approvalRequestSelect
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY r.requested_time DESC, r.id DESC"
+
+	rows, err := r.db.QueryContext(ctx, database.ConvertPlaceholders(query), args...)
+	if err != nil {
+		return nil, fmt.Errorf("query approval requests: %w", err)
+	}
+	defer rows.Close()
+
+	reqs := make([]models.ApprovalRequest, 0)
+	for rows.Next() {
+		req, err := scanApprovalRequest(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan approval request: %w", err)
+		}
+		reqs = append(reqs, *req)
+	}
+	return reqs, rows.Err()
+}
+
+// Decide records the decision on a pending request. When the request is
+// approved, its change is applied to the ticket in the same transaction.
+// ErrApprovalAlreadyDecided is returned when the request is not pending.
+func (r *ApprovalRepository) Decide(ctx context.Context, req *models.ApprovalRequest, status models.ApprovalStatus, userID int, comment string, now time.Time) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin approval decision: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	result, err := tx.ExecContext(ctx, database.ConvertPlaceholders(`
+		UPDATE ticket_approval_request
+		SET status = ?, decided_by = ?, decided_time = ?, decision_comment = ?
+		WHERE id = ? AND status = ?
+	`), string(status), userID, now, comment, req.ID, string(models.ApprovalStatusPending))
+	if err != nil {
+		return fmt.Errorf("update approval request: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrApprovalAlreadyDecided
+	}
+
+	if status == models.ApprovalStatusApproved {
+		column := "ticket_state_id"
+		if req.Action == models.ApprovalActionMove {
+			column = "queue_id"
+		}
+		_, err = tx.ExecContext(ctx, database.ConvertPlaceholders(`
+			UPDATE ticket SET `+column+` = ?, change_time = ?, change_by = ?
+			WHERE id = ?
+		`), req.TargetID, now, userID, req.TicketID)
+		if err != nil {
+			return fmt.Errorf("apply approved %s to ticket %d: %w", req.Action, req.TicketID, err)
+		}
+	}
+	return tx.Commit()
+}
+
+func scanApprovalRule(row kbRowScanner) (*models.ApprovalRule, error) {
+	var rule models.ApprovalRule
+	var queueID sql.NullInt64
+	var action string
+	if err := row.Scan(&rule.ID, &rule.Name, &queueID, &action, &rule.ApproverGroupID, &rule.Comments,
+		&rule.ValidID, &rule.CreateTime, &rule.CreateBy, &rule.ChangeTime, &rule.ChangeBy); err != nil {
+		return nil, err
+	}
+	rule.Action = models.ApprovalAction(action)
+	if queueID.Valid {
+		id := int(queueID.Int64)
+		rule.QueueID = &id
+	}
+	return &rule, nil
+}
+
+func scanApprovalRequest(row kbRowScanner) (*models.ApprovalRequest, error) {
+	var req models.ApprovalRequest
+	var action, status string
+	var decidedBy sql.NullInt64
+	var decidedTime sql.NullTime
+	if err := row.Scan(&req.ID, &req.RuleID, &req.RuleName, &req.ApproverGroupID, &req.TicketID, &req.TicketNumber,
+		&req.QueueID, &action, &req.TargetID, &req.Reason, &status,
+		&req.RequestedBy, &req.RequestedTime, &decidedBy, &decidedTime, &req.DecisionComment); err != nil {
+		return nil, err
+	}
+	req.Action = models.ApprovalAction(action)
+	req.Status = models.ApprovalStatus(status)
+	if decidedBy.Valid {
+		id := int(decidedBy.Int64)
+		req.DecidedBy = &id
+	}
+	if decidedTime.Valid {
+		req.DecidedTime = &decidedTime.Time
+	}
+	return &req, nil
+}
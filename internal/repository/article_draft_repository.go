@@ -0,0 +1,192 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/models"
+)
+
+const articleDraftSelect = `
+	SELECT id, ticket_id, user_id, shared, subject, body, a_to, a_cc, a_bcc, content_type,
+	       is_visible_for_customer, base_article_id, version, create_time, change_time, change_by
+	FROM article_draft`
+
+// ArticleDraftRepository handles database operations for article drafts.
+type ArticleDraftRepository struct {
+	db *sql.DB
+}
+
+// NewArticleDraftRepository creates a new article draft repository.
+func NewArticleDraftRepository(db *sql.DB) *ArticleDraftRepository {
+	return &ArticleDraftRepository{db: db}
+}
+
+// Get returns a draft, or nil if it does not exist.
+func (r *ArticleDraftRepository) Get(ctx context.Context, id int64) (*models.ArticleDraft, error) {
+	return r.getOne(ctx, articleDraftSelect+" WHERE id = ?", id)
+}
+
+// GetForUser returns an agent's draft for a ticket, or nil if there is none.
+func (r *ArticleDraftRepository) GetForUser(ctx context.Context, ticketID int64, userID int) (*models.ArticleDraft, error) {
+	return r.getOne(ctx, articleDraftSelect+" WHERE ticket_id = ? AND user_id = ?", ticketID, userID)
+}
+
+func (r *ArticleDraftRepository) getOne(ctx context.Context, query string, args ...interface{}) (*models.ArticleDraft, error) {
+	draft, err := scanArticleDraft(r.db.QueryRowContext(ctx, database.ConvertPlaceholders(query), args...))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query article draft: %w", err)
+	}
+	return draft, nil
+}
+
+// ListForTicket returns the drafts of a ticket an agent may see: their own
+// and the shared drafts of others, most recently changed first.
+func (r *ArticleDraftRepository) ListForTicket(ctx context.Context, ticketID int64, userID int) ([]*models.ArticleDraft, error) {
+	rows, err := r.db.QueryContext(ctx, database.ConvertPlaceholders(articleDraftSelect+`
+		WHERE ticket_id = ? AND (user_id = ? OR shared = 1)
+		ORDER BY change_time DESC, id DESC`), ticketID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("query article drafts: %w", err)
+	}
+	defer rows.Close()
+
+	drafts := []*models.ArticleDraft{}
+	for rows.Next() {
+		draft, err := scanArticleDraft(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan article draft: %w", err)
+		}
+		drafts = append(drafts, draft)
+	}
+	return drafts, rows.Err()
+}
+
+// Create stores a new draft and sets its ID.
+func (r *ArticleDraftRepository) Create(ctx context.Context, draft *models.ArticleDraft) error {
+	id, err := database.GetAdapter().InsertWithReturning(r.db, database.ConvertPlaceholders(`
+		INSERT INTO article_draft (ticket_id, user_id, shared, subject, body, a_to, a_cc, a_bcc, content_type,
+			is_visible_for_customer, base_article_id, version, create_time, change_time, change_by)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id`),
+		draft.TicketID, draft.UserID, boolToSmallint(draft.Shared), draft.Subject, draft.Body,
+		draft.To, draft.Cc, draft.Bcc, draft.ContentType, boolToSmallint(draft.IsVisibleForCustomer),
+		draft.BaseArticleID, draft.Version, draft.CreateTime, draft.ChangeTime, draft.ChangeBy)
+	if err != nil {
+		return fmt.Errorf("insert article draft: %w", err)
+	}
+	draft.ID = id
+	return nil
+}
+
+// Update stores the content of a draft whose version is still
+// previousVersion. It returns sql.ErrNoRows when the draft does not exist
+// or was saved by someone else in between.
+func (r *ArticleDraftRepository) Update(ctx context.Context, draft *models.ArticleDraft, previousVersion int) error {
+	result, err := r.db.ExecContext(ctx, database.ConvertPlaceholders(`
+		UPDATE article_draft
+		SET shared = ?, subject = ?, body = ?, a_to = ?, a_cc = ?, a_bcc = ?, content_type = ?,
+		    is_visible_for_customer = ?, base_article_id = ?, version = ?, change_time = ?, change_by = ?
+		WHERE id = ? AND version = ?`),
+		boolToSmallint(draft.Shared), draft.Subject, draft.Body, draft.To, draft.Cc, draft.Bcc,
+		draft.ContentType, boolToSmallint(draft.IsVisibleForCustomer), draft.BaseArticleID, draft.Version,
+		draft.ChangeTime, draft.ChangeBy, draft.ID, previousVersion)
+	if err != nil {
+		return fmt.Errorf("update article draft: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// DeleteForUser removes an agent's draft for a ticket.
+func (r *ArticleDraftRepository) DeleteForUser(ctx context.Context, ticketID int64, userID int) (bool, error) {
+	result, err := r.db.ExecContext(ctx, database.ConvertPlaceholders(
+		"DELETE FROM article_draft WHERE ticket_id = ? AND user_id = ?"), ticketID, userID)
+	if err != nil {
+		return false, fmt.Errorf("delete article draft: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// DeleteChangedBefore removes the drafts last changed before cutoff.
+func (r *ArticleDraftRepository) DeleteChangedBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	result, err := r.db.ExecContext(ctx, database.ConvertPlaceholders(
+		"DELETE FROM article_draft WHERE change_time < ?"), cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("purge article drafts: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// LatestArticleID returns the ID of the newest article of a ticket, 0 when
+// it has none.
+func (r *ArticleDraftRepository) LatestArticleID(ctx context.Context, ticketID int64) (int64, error) {
+	var id sql.NullInt64
+	if err := r.db.QueryRowContext(ctx, database.ConvertPlaceholders(
+		"SELECT MAX(id) FROM article WHERE ticket_id = ?"), ticketID).Scan(&id); err != nil {
+		return 0, fmt.Errorf("query latest article: %w", err)
+	}
+	return id.Int64, nil
+}
+
+// ArticlesSince returns the articles others than userID added to a ticket
+// after afterID, or nil when there are none.
+func (r *ArticleDraftRepository) ArticlesSince(ctx context.Context, ticketID, afterID int64, userID int) (*models.ArticleDraftConflict, error) {
+	var conflict models.ArticleDraftConflict
+	if err := r.db.QueryRowContext(ctx, database.ConvertPlaceholders(`
+		SELECT COUNT(*), COALESCE(MAX(id), 0)
+		FROM article
+		WHERE ticket_id = ? AND id > ? AND create_by <> ?`), ticketID, afterID, userID).Scan(
+		&conflict.Articles, &conflict.LatestArticleID); err != nil {
+		return nil, fmt.Errorf("query articles since draft: %w", err)
+	}
+	if conflict.Articles == 0 {
+		return nil, nil
+	}
+	if err := r.db.QueryRowContext(ctx, database.ConvertPlaceholders(
+		"SELECT create_by FROM article WHERE id = ?"), conflict.LatestArticleID).Scan(&conflict.LatestBy); err != nil {
+		return nil, fmt.Errorf("query latest article author: %w", err)
+	}
+	return &conflict, nil
+}
+
+// TicketExists reports whether the ticket exists.
+func (r *ArticleDraftRepository) TicketExists(ctx context.Context, ticketID int64) (bool, error) {
+	var count int
+	if err := r.db.QueryRowContext(ctx, database.ConvertPlaceholders(
+		"SELECT COUNT(*) FROM ticket WHERE id = ?"), ticketID).Scan(&count); err != nil {
+		return false, fmt.Errorf("check ticket: %w", err)
+	}
+	return count > 0, nil
+}
+
+func scanArticleDraft(row kbRowScanner) (*models.ArticleDraft, error) {
+	var draft models.ArticleDraft
+	var shared, visible int
+	var subject, body, to, cc, bcc, contentType sql.NullString
+	if err := row.Scan(&draft.ID, &draft.TicketID, &draft.UserID, &shared, &subject, &body, &to, &cc, &bcc,
+		&contentType, &visible, &draft.BaseArticleID, &draft.Version, &draft.CreateTime, &draft.ChangeTime,
+		&draft.ChangeBy); err != nil {
+		return nil, err
+	}
+	draft.Shared, draft.IsVisibleForCustomer = shared == 1, visible == 1
+	draft.Subject, draft.Body, draft.To, draft.Cc, draft.Bcc = subject.String, body.String, to.String, cc.String, bcc.String
+	draft.ContentType = contentType.String
+	return &draft, nil
+}
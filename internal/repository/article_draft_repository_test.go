@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/testutil"
+)
+
+func TestArticleDraftRepository(t *testing.T) {
+	db := testutil.UseMigratedDB(t)
+	for id, login := range map[int]string{1: "root@localhost", 2: "agent"} {
+		_, err := db.Exec(`INSERT INTO users (id, login, pw, first_name, last_name, valid_id, create_time, create_by, change_time, change_by)
+			VALUES (?, ?, 'x', 'Test', 'Agent', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`, id, login)
+		require.NoError(t, err)
+	}
+
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+	insertArchiveTestTicket(t, db, 1, 1, now)
+	repo := NewArticleDraftRepository(db)
+
+	latest, err := repo.LatestArticleID(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(10), latest)
+
+	draft := &models.ArticleDraft{TicketID: 1, UserID: 1, Subject: "Re: Printer", Body: "Have you tried",
+		ContentType: "text/plain", BaseArticleID: latest, Version: 1, CreateTime: now, ChangeTime: now, ChangeBy: 1}
+	require.NoError(t, repo.Create(ctx, draft))
+	require.NotZero(t, draft.ID)
+	shared := &models.ArticleDraft{TicketID: 1, UserID: 2, Shared: true, Body: "Shared notes",
+		Version: 1, CreateTime: now, ChangeTime: now, ChangeBy: 2}
+	require.NoError(t, repo.Create(ctx, shared))
+	private := &models.ArticleDraft{TicketID: 1, UserID: 2, Body: "Private"}
+	assert.Error(t, repo.Create(ctx, private), "one draft per agent and ticket")
+
+	loaded, err := repo.GetForUser(ctx, 1, 1)
+	require.NoError(t, err)
+	require.NotNil(t, loaded)
+	assert.Equal(t, "Have you tried", loaded.Body)
+	assert.False(t, loaded.Shared)
+	missing, err := repo.GetForUser(ctx, 1, 9)
+	require.NoError(t, err)
+	assert.Nil(t, missing)
+
+	drafts, err := repo.ListForTicket(ctx, 1, 1)
+	require.NoError(t, err)
+	assert.Len(t, drafts, 2, "own and shared drafts")
+	_, err = db.Exec("UPDATE article_draft SET shared = 0 WHERE id = ?", shared.ID)
+	require.NoError(t, err)
+	drafts, err = repo.ListForTicket(ctx, 1, 1)
+	require.NoError(t, err)
+	assert.Len(t, drafts, 1, "private drafts of others are hidden")
+
+	draft.Body, draft.Version, draft.ChangeTime = "Have you tried turning it off", 2, now.Add(time.Minute)
+	require.NoError(t, repo.Update(ctx, draft, 1))
+	draft.Version = 3
+	assert.Equal(t, sql.ErrNoRows, repo.Update(ctx, draft, 1), "stale versions are refused")
+	loaded, err = repo.Get(ctx, draft.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Have you tried turning it off", loaded.Body)
+	assert.Equal(t, 2, loaded.Version)
+
+	conflict, err := repo.ArticlesSince(ctx, 1, 10, 1)
+	require.NoError(t, err)
+	assert.Nil(t, conflict)
+	for id, by := range map[int]int{11: 1, 12: 2} {
+		_, err = db.Exec(`INSERT INTO article (id, ticket_id, article_sender_type_id, communication_channel_id,
+			is_visible_for_customer, create_time, create_by, change_time, change_by) VALUES (?, 1, 1, 1, 1, ?, ?, ?, ?)`,
+			id, now, by, now, by)
+		require.NoError(t, err)
+	}
+	conflict, err = repo.ArticlesSince(ctx, 1, 10, 1)
+	require.NoError(t, err)
+	require.NotNil(t, conflict, "articles by others conflict")
+	assert.Equal(t, 1, conflict.Articles)
+	assert.Equal(t, int64(12), conflict.LatestArticleID)
+	assert.Equal(t, 2, conflict.LatestBy)
+
+	n, err := repo.DeleteChangedBefore(ctx, now.Add(30*time.Second))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n, "only the draft unchanged since before the cutoff")
+	deleted, err := repo.DeleteForUser(ctx, 1, 1)
+	require.NoError(t, err)
+	assert.True(t, deleted)
+	deleted, err = repo.DeleteForUser(ctx, 1, 1)
+	require.NoError(t, err)
+	assert.False(t, deleted)
+
+	exists, err := repo.TicketExists(ctx, 1)
+	require.NoError(t, err)
+	assert.True(t, exists)
+	exists, err = repo.TicketExists(ctx, 99)
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
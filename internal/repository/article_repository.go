@@ -11,6 +11,7 @@ import (
 
 	"github.com/goatkit/goatflow/internal/database"
 	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/storage"
 )
 
 // ArticleRepository handles database operations for articles.
@@ -355,7 +356,7 @@ func (r *ArticleRepository) GetByID(id uint) (*models.Article, error) {
 		article.Subject = subject.String
 	}
 	if bodyBytes != nil {
-		article.Body = string(bodyBytes)
+		article.Body = storage.DecodeText(string(bodyBytes))
 	}
 	if contentType.Valid {
 		article.MimeType = contentType.String
@@ -407,7 +408,7 @@ func (r *ArticleRepository) GetHTMLBodyContent(articleID uint) (string, error) {
 		return "", err
 	}
 
-	return string(content), nil
+	return string(storage.DecodeBytes(content)), nil
 }
 
 // GetByTicketID retrieves all articles for a specific ticket.
@@ -467,7 +468,7 @@ func (r *ArticleRepository) GetByTicketID(ticketID uint, includeInternal bool) (
 			article.Subject = subject.String
 		}
 		if bodyBytes != nil {
-			article.Body = string(bodyBytes)
+			article.Body = storage.DecodeText(string(bodyBytes))
 		}
 		if contentType.Valid {
 			article.MimeType = contentType.String
@@ -669,7 +670,7 @@ func (r *ArticleRepository) GetLatestArticleForTicket(ticketID uint) (*models.Ar
 		article.Subject = subject.String
 	}
 	if body.Valid {
-		article.Body = body.String
+		article.Body = storage.DecodeText(body.String)
 	}
 	if contentType.Valid {
 		article.MimeType = contentType.String
@@ -755,7 +756,7 @@ func (r *ArticleRepository) GetLatestCustomerArticleForTicket(ticketID uint) (*m
 		article.Subject = subject.String
 	}
 	if body.Valid {
-		article.Body = body.String
+		article.Body = storage.DecodeText(body.String)
 	}
 	if contentType.Valid {
 		article.MimeType = contentType.String
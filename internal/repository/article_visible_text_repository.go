@@ -0,0 +1,96 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/models"
+)
+
+// ArticleVisibleTextRepository stores the visible text of inbound email
+// articles: their body without quoted messages and signature.
+type ArticleVisibleTextRepository struct {
+	db *sql.DB
+}
+
+// NewArticleVisibleTextRepository creates a new article visible text
+// repository.
+func NewArticleVisibleTextRepository(db *sql.DB) *ArticleVisibleTextRepository {
+	return &ArticleVisibleTextRepository{db: db}
+}
+
+// Save stores the visible text of an article, replacing the one it had.
+func (r *ArticleVisibleTextRepository) Save(ctx context.Context, vt *models.ArticleVisibleText) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin visible text: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(ctx, database.ConvertPlaceholders(
+		"DELETE FROM article_visible_text WHERE article_id = ?"), vt.ArticleID); err != nil {
+		return fmt.Errorf("delete visible text: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, database.ConvertPlaceholders(`
+		INSERT INTO article_visible_text (article_id, visible_text, has_quote, has_signature, create_time)
+		VALUES (?, ?, ?, ?, ?)`),
+		vt.ArticleID, vt.VisibleText, boolToSmallint(vt.HasQuote), boolToSmallint(vt.HasSignature), vt.CreateTime); err != nil {
+		return fmt.Errorf("insert visible text: %w", err)
+	}
+	return tx.Commit()
+}
+
+// Get returns the visible text of an article, or nil if it has none.
+func (r *ArticleVisibleTextRepository) Get(ctx context.Context, articleID int64) (*models.ArticleVisibleText, error) {
+	list, err := r.GetForArticles(ctx, []int64{articleID})
+	if err != nil {
+		return nil, err
+	}
+	return list[articleID], nil
+}
+
+// GetForArticles returns the visible texts of articles by article ID.
+// Articles without one are left out.
+func (r *ArticleVisibleTextRepository) GetForArticles(ctx context.Context, articleIDs []int64) (map[int64]*models.ArticleVisibleText, error) {
+	texts := make(map[int64]*models.ArticleVisibleText, len(articleIDs))
+	if len(articleIDs) == 0 {
+		return texts, nil
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(articleIDs)), ",")
+	args := make([]interface{}, len(articleIDs))
+	for i, id := range articleIDs {
+		args[i] = id
+	}
+	rows, err := r.db.QueryContext(ctx, database.ConvertPlaceholders(`
+		SELECT article_id, visible_text, has_quote, has_signature, create_time
+		FROM article_visible_text
+		WHERE article_id IN (`+placeholders+`)`), args...)
+	if err != nil {
+		return nil, fmt.Errorf("query visible text: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var vt models.ArticleVisibleText
+		var hasQuote, hasSignature int
+		if err := rows.Scan(&vt.ArticleID, &vt.VisibleText, &hasQuote, &hasSignature, &vt.CreateTime); err != nil {
+			return nil, fmt.Errorf("scan visible text: %w", err)
+		}
+		vt.HasQuote = hasQuote == 1
+		vt.HasSignature = hasSignature == 1
+		texts[vt.ArticleID] = &vt
+	}
+	return texts, rows.Err()
+}
+
+// Delete removes the visible text of an article.
+func (r *ArticleVisibleTextRepository) Delete(ctx context.Context, articleID int64) error {
+	if _, err := r.db.ExecContext(ctx, database.ConvertPlaceholders(
+		"DELETE FROM article_visible_text WHERE article_id = ?"), articleID); err != nil {
+		return fmt.Errorf("delete visible text: %w", err)
+	}
+	return nil
+}
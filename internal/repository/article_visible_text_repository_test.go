@@ -0,0 +1,45 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/testutil"
+)
+
+func TestArticleVisibleTextRepository(t *testing.T) {
+	db := testutil.UseMigratedDB(t)
+	repo := NewArticleVisibleTextRepository(db)
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+
+	vt, err := repo.Get(ctx, 10)
+	require.NoError(t, err)
+	assert.Nil(t, vt)
+
+	require.NoError(t, repo.Save(ctx, &models.ArticleVisibleText{ArticleID: 10, VisibleText: "Thanks", HasQuote: true, CreateTime: now}))
+	require.NoError(t, repo.Save(ctx, &models.ArticleVisibleText{ArticleID: 10, VisibleText: "Thanks!", HasSignature: true, CreateTime: now}))
+	require.NoError(t, repo.Save(ctx, &models.ArticleVisibleText{ArticleID: 11, VisibleText: "Still broken", HasQuote: true, CreateTime: now}))
+
+	vt, err = repo.Get(ctx, 10)
+	require.NoError(t, err)
+	require.NotNil(t, vt)
+	assert.Equal(t, "Thanks!", vt.VisibleText)
+	assert.False(t, vt.HasQuote)
+	assert.True(t, vt.HasSignature)
+
+	texts, err := repo.GetForArticles(ctx, []int64{10, 11, 12})
+	require.NoError(t, err)
+	assert.Len(t, texts, 2)
+	assert.Equal(t, "Still broken", texts[11].VisibleText)
+
+	require.NoError(t, repo.Delete(ctx, 10))
+	vt, err = repo.Get(ctx, 10)
+	require.NoError(t, err)
+	assert.Nil(t, vt)
+}
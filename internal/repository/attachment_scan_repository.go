@@ -0,0 +1,287 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/models"
+)
+
+// ErrQuarantineReleased is returned when a quarantined attachment was
+// already released.
+var ErrQuarantineReleased = errors.New("attachment was already released from quarantine")
+
+// ArticleFlagVirusDetected is the article_flag key set on articles that had
+// an infected attachment. Its value is the signature found.
+const ArticleFlagVirusDetected = "VirusDetected"
+
+const quarantineSelect = `
+	SELECT q.id, q.ticket_id, COALESCE(t.tn, ''), q.article_id, q.filename, COALESCE(q.content_type, ''),
+	       q.content_size, q.status, COALESCE(q.signature, ''), q.scanner, q.source,
+	       q.create_time, q.create_by, q.released_at, q.released_by
+	FROM attachment_quarantine q
+	LEFT JOIN ticket t ON t.id = q.ticket_id`
+
+// AttachmentScanRepository stores attachment scan records and the
+// attachment quarantine.
+type AttachmentScanRepository struct {
+	db *sql.DB
+}
+
+// NewAttachmentScanRepository creates a new attachment scan repository.
+func NewAttachmentScanRepository(db *sql.DB) *AttachmentScanRepository {
+	return &AttachmentScanRepository{db: db}
+}
+
+// RecordScan stores the scan of an attachment, setting its ID.
+func (r *AttachmentScanRepository) RecordScan(ctx context.Context, scan *models.AttachmentScan) error {
+	id, err := database.GetAdapter().InsertWithReturning(r.db, database.ConvertPlaceholders(`
+		INSERT INTO attachment_scan (article_id, filename, status, signature, scanner, source, scanned_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		RETURNING id`),
+		scan.ArticleID, scan.Filename, string(scan.Status), truncateScanText(scan.Signature),
+		scan.Scanner, scan.Source, scan.ScannedAt)
+	if err != nil {
+		return fmt.Errorf("insert attachment scan: %w", err)
+	}
+	scan.ID = id
+	return nil
+}
+
+// LatestScan returns the latest scan of an article's attachment, or nil if
+// it was not scanned.
+func (r *AttachmentScanRepository) LatestScan(ctx context.Context, articleID int64, filename string) (*models.AttachmentScan, error) {
+	var scan models.AttachmentScan
+	var status string
+	err := r.db.QueryRowContext(ctx, database.ConvertPlaceholders(`
+		SELECT id, article_id, filename, status, COALESCE(signature, ''), scanner, source, scanned_at
+		FROM attachment_scan
+		WHERE article_id = ? AND filename = ?
+		ORDER BY id DESC
+		LIMIT 1`), articleID, filename).Scan(
+		&scan.ID, &scan.ArticleID, &scan.Filename, &status, &scan.Signature, &scan.Scanner, &scan.Source, &scan.ScannedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query attachment scan: %w", err)
+	}
+	scan.Status = models.AttachmentScanStatus(status)
+	return &scan, nil
+}
+
+// Quarantine stores an attachment held back from its article, setting its
+// ID.
+func (r *AttachmentScanRepository) Quarantine(ctx context.Context, q *models.QuarantinedAttachment) error {
+	id, err := database.GetAdapter().InsertWithReturning(r.db, database.ConvertPlaceholders(`
+		INSERT INTO attachment_quarantine (
+			ticket_id, article_id, filename, content_type, content_size, content,
+			status, signature, scanner, source, create_time, create_by
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id`),
+		q.TicketID, q.ArticleID, q.Filename, q.ContentType, int64(len(q.Content)), q.Content,
+		string(q.Status), truncateScanText(q.Signature), q.Scanner, q.Source, q.CreateTime, q.CreateBy)
+	if err != nil {
+		return fmt.Errorf("insert quarantined attachment: %w", err)
+	}
+	q.ID = id
+	q.ContentSize = int64(len(q.Content))
+	return nil
+}
+
+// ListQuarantine returns quarantined attachments, newest first. Released
+// ones are only included with includeReleased.
+func (r *AttachmentScanRepository) ListQuarantine(ctx context.Context, includeReleased bool, limit, offset int) ([]*models.QuarantinedAttachment, error) {
+	query := quarantineSelect
+	if !includeReleased {
+		query += " WHERE q.released_at IS NULL"
+	}
+	query += " ORDER BY q.create_time DESC, q.id DESC LIMIT ? OFFSET ?"
+	rows, err := r.db.QueryContext(ctx, database.ConvertPlaceholders(query), limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("query quarantine: %w", err)
+	}
+	defer rows.Close()
+
+	var list []*models.QuarantinedAttachment
+	for rows.Next() {
+		q, err := scanQuarantinedAttachment(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan quarantined attachment: %w", err)
+		}
+		list = append(list, q)
+	}
+	return list, rows.Err()
+}
+
+// GetQuarantine returns a quarantined attachment without its content, or
+// nil if it does not exist.
+func (r *AttachmentScanRepository) GetQuarantine(ctx context.Context, id int64) (*models.QuarantinedAttachment, error) {
+	q, err := scanQuarantinedAttachment(r.db.QueryRowContext(ctx,
+		database.ConvertPlaceholders(quarantineSelect+" WHERE q.id = ?"), id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query quarantined attachment: %w", err)
+	}
+	return q, nil
+}
+
+// Release adds a quarantined attachment to its article and records it as
+// released. It returns ErrQuarantineReleased when it was released before.
+func (r *AttachmentScanRepository) Release(ctx context.Context, id int64, userID int, now time.Time) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin release: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	res, err := tx.ExecContext(ctx, database.ConvertPlaceholders(`
+		UPDATE attachment_quarantine SET released_at = ?, released_by = ?
+		WHERE id = ? AND released_at IS NULL`), now, userID, id)
+	if err != nil {
+		return fmt.Errorf("mark attachment released: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrQuarantineReleased
+	}
+
+	var articleID int64
+	var filename, contentType, scanner, source string
+	var content []byte
+	if err := tx.QueryRowContext(ctx, database.ConvertPlaceholders(`
+		SELECT article_id, filename, COALESCE(content_type, ''), content, scanner, source
+		FROM attachment_quarantine WHERE id = ?`), id).Scan(
+		&articleID, &filename, &contentType, &content, &scanner, &source); err != nil {
+		return fmt.Errorf("load quarantined attachment: %w", err)
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	if _, err := tx.ExecContext(ctx, database.ConvertPlaceholders(`
+		INSERT INTO article_data_mime_attachment (
+			article_id, filename, content_type, content_size, content,
+			disposition, create_time, create_by, change_time, change_by
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`),
+		articleID, filename, contentType, int64(len(content)), content,
+		"attachment", now, userID, now, userID); err != nil {
+		return fmt.Errorf("insert released attachment: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, database.ConvertPlaceholders(`
+		INSERT INTO attachment_scan (article_id, filename, status, signature, scanner, source, scanned_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`),
+		articleID, filename, string(models.ScanStatusReleased), nil, scanner, source, now); err != nil {
+		return fmt.Errorf("record released attachment: %w", err)
+	}
+	return tx.Commit()
+}
+
+// DeleteQuarantine deletes a quarantined attachment, reporting whether it
+// existed.
+func (r *AttachmentScanRepository) DeleteQuarantine(ctx context.Context, id int64) (bool, error) {
+	res, err := r.db.ExecContext(ctx, database.ConvertPlaceholders(
+		"DELETE FROM attachment_quarantine WHERE id = ?"), id)
+	if err != nil {
+		return false, fmt.Errorf("delete quarantined attachment: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// FlagArticle sets an article flag, replacing the value it had.
+func (r *AttachmentScanRepository) FlagArticle(ctx context.Context, articleID int64, key, value string, userID int, now time.Time) error {
+	if len(value) > 50 {
+		value = value[:50]
+	}
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin article flag: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(ctx, database.ConvertPlaceholders(
+		"DELETE FROM article_flag WHERE article_id = ? AND article_key = ?"), articleID, key); err != nil {
+		return fmt.Errorf("delete article flag: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, database.ConvertPlaceholders(`
+		INSERT INTO article_flag (article_id, article_key, article_value, create_time, create_by)
+		VALUES (?, ?, ?, ?, ?)`), articleID, key, value, now, userID); err != nil {
+		return fmt.Errorf("insert article flag: %w", err)
+	}
+	return tx.Commit()
+}
+
+// TicketNumber returns the number of a ticket, or "" if it does not exist.
+func (r *AttachmentScanRepository) TicketNumber(ctx context.Context, ticketID int64) (string, error) {
+	var tn string
+	err := r.db.QueryRowContext(ctx, database.ConvertPlaceholders(
+		"SELECT tn FROM ticket WHERE id = ?"), ticketID).Scan(&tn)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return tn, err
+}
+
+// AdminEmails returns the logins of valid members of the admin group that
+// are email addresses.
+func (r *AttachmentScanRepository) AdminEmails(ctx context.Context) ([]string, error) {
+	rows, err := r.db.QueryContext(ctx, database.ConvertPlaceholders(`
+		SELECT DISTINCT u.login
+		FROM users u
+		JOIN group_user gu ON gu.user_id = u.id
+		JOIN groups g ON g.id = gu.group_id
+		WHERE u.valid_id = 1 AND g.valid_id = 1 AND g.name = ?
+		ORDER BY u.login
+	`), "admin")
+	if err != nil {
+		return nil, fmt.Errorf("query admins: %w", err)
+	}
+	defer rows.Close()
+
+	emails := make([]string, 0)
+	for rows.Next() {
+		var login string
+		if err := rows.Scan(&login); err != nil {
+			return nil, fmt.Errorf("scan admin: %w", err)
+		}
+		if strings.Contains(login, "@") {
+			emails = append(emails, login)
+		}
+	}
+	return emails, rows.Err()
+}
+
+func scanQuarantinedAttachment(row kbRowScanner) (*models.QuarantinedAttachment, error) {
+	var q models.QuarantinedAttachment
+	var status string
+	var releasedAt sql.NullTime
+	var releasedBy sql.NullInt64
+	if err := row.Scan(&q.ID, &q.TicketID, &q.TicketNumber, &q.ArticleID, &q.Filename, &q.ContentType,
+		&q.ContentSize, &status, &q.Signature, &q.Scanner, &q.Source,
+		&q.CreateTime, &q.CreateBy, &releasedAt, &releasedBy); err != nil {
+		return nil, err
+	}
+	q.Status = models.AttachmentScanStatus(status)
+	if releasedAt.Valid {
+		q.ReleasedAt = &releasedAt.Time
+	}
+	q.ReleasedBy = intPtrFromNull(releasedBy)
+	return &q, nil
+}
+
+// truncateScanText fits a signature or scanner error into its column.
+func truncateScanText(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	if len(s) > 250 {
+		s = s[:250]
+	}
+	return s
+}
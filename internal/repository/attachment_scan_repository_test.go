@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/testutil"
+)
+
+func TestAttachmentScanRepository(t *testing.T) {
+	db := testutil.UseMigratedDB(t)
+	_, err := db.Exec(`INSERT INTO users (id, login, pw, first_name, last_name, valid_id, create_time, create_by, change_time, change_by)
+		VALUES (1, 'root@localhost', 'x', 'Admin', 'OTRS', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`)
+	require.NoError(t, err)
+	insertArchiveTestTicket(t, db, 1, 2, time.Now())
+
+	repo := NewAttachmentScanRepository(db)
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+
+	require.NoError(t, repo.RecordScan(ctx, &models.AttachmentScan{
+		ArticleID: 10, Filename: "smoke.jpg", Status: models.ScanStatusClean,
+		Scanner: "clamd", Source: models.AttachmentSourceUpload, ScannedAt: now,
+	}))
+	scan, err := repo.LatestScan(ctx, 10, "smoke.jpg")
+	require.NoError(t, err)
+	require.NotNil(t, scan)
+	assert.Equal(t, models.ScanStatusClean, scan.Status)
+	scan, err = repo.LatestScan(ctx, 10, "other.pdf")
+	require.NoError(t, err)
+	assert.Nil(t, scan)
+
+	q := &models.QuarantinedAttachment{
+		TicketID: 1, ArticleID: 10, Filename: "invoice.exe", ContentType: "application/x-msdownload",
+		Content: []byte("MZ"), Status: models.ScanStatusInfected, Signature: "Win.Test.EICAR_HDB-1",
+		Scanner: "clamd", Source: models.AttachmentSourceEmail, CreateTime: now, CreateBy: 1,
+	}
+	require.NoError(t, repo.Quarantine(ctx, q))
+	require.NotZero(t, q.ID)
+	require.NoError(t, repo.FlagArticle(ctx, 10, ArticleFlagVirusDetected, q.Signature, 1, now))
+	require.NoError(t, repo.FlagArticle(ctx, 10, ArticleFlagVirusDetected, q.Signature, 1, now))
+	assert.Equal(t, 1, countRows(t, db, "SELECT COUNT(*) FROM article_flag WHERE article_id = 10"))
+
+	list, err := repo.ListQuarantine(ctx, false, 50, 0)
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, "20250101000001", list[0].TicketNumber)
+	assert.Equal(t, int64(2), list[0].ContentSize)
+	assert.Nil(t, list[0].ReleasedAt)
+
+	require.NoError(t, repo.Release(ctx, q.ID, 1, now))
+	assert.ErrorIs(t, repo.Release(ctx, q.ID, 1, now), ErrQuarantineReleased)
+	assert.Equal(t, 1, countRows(t, db, "SELECT COUNT(*) FROM article_data_mime_attachment WHERE filename = 'invoice.exe'"))
+	scan, err = repo.LatestScan(ctx, 10, "invoice.exe")
+	require.NoError(t, err)
+	assert.Equal(t, models.ScanStatusReleased, scan.Status)
+
+	list, err = repo.ListQuarantine(ctx, false, 50, 0)
+	require.NoError(t, err)
+	assert.Empty(t, list)
+	got, err := repo.GetQuarantine(ctx, q.ID)
+	require.NoError(t, err)
+	require.NotNil(t, got.ReleasedBy)
+	assert.Equal(t, 1, *got.ReleasedBy)
+
+	deleted, err := repo.DeleteQuarantine(ctx, q.ID)
+	require.NoError(t, err)
+	assert.True(t, deleted)
+	got, err = repo.GetQuarantine(ctx, q.ID)
+	require.NoError(t, err)
+	assert.Nil(t, got)
+}
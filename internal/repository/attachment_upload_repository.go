@@ -0,0 +1,187 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/models"
+)
+
+// ErrUploadOffset is returned when a chunk does not start where the upload
+// currently ends, e.g. because it was sent twice.
+var ErrUploadOffset = errors.New("chunk does not continue the upload")
+
+// AttachmentUploadRepository stores chunked attachment uploads until they
+// are complete.
+type AttachmentUploadRepository struct {
+	db *sql.DB
+}
+
+// NewAttachmentUploadRepository creates a new attachment upload repository.
+func NewAttachmentUploadRepository(db *sql.DB) *AttachmentUploadRepository {
+	return &AttachmentUploadRepository{db: db}
+}
+
+// Create starts an upload.
+func (r *AttachmentUploadRepository) Create(ctx context.Context, u *models.AttachmentUpload) error {
+	var articleID interface{}
+	if u.ArticleID > 0 {
+		articleID = u.ArticleID
+	}
+	_, err := r.db.ExecContext(ctx, database.ConvertPlaceholders(`
+		INSERT INTO attachment_upload (id, ticket_id, article_id, filename, content_type, total_size,
+			received_size, user_id, user_role, create_time, change_time)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`),
+		u.ID, u.TicketID, articleID, u.Filename, u.ContentType, u.TotalSize,
+		u.ReceivedSize, u.UserID, u.UserRole, u.CreateTime, u.ChangeTime)
+	if err != nil {
+		return fmt.Errorf("insert attachment upload: %w", err)
+	}
+	return nil
+}
+
+// GetByID returns an upload, or nil if there is none.
+func (r *AttachmentUploadRepository) GetByID(ctx context.Context, id string) (*models.AttachmentUpload, error) {
+	var (
+		u         models.AttachmentUpload
+		articleID sql.NullInt64
+	)
+	err := r.db.QueryRowContext(ctx, database.ConvertPlaceholders(`
+		SELECT id, ticket_id, article_id, filename, COALESCE(content_type, ''), total_size,
+			received_size, user_id, user_role, create_time, change_time
+		FROM attachment_upload WHERE id = ?`), id).
+		Scan(&u.ID, &u.TicketID, &articleID, &u.Filename, &u.ContentType, &u.TotalSize,
+			&u.ReceivedSize, &u.UserID, &u.UserRole, &u.CreateTime, &u.ChangeTime)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query attachment upload: %w", err)
+	}
+	u.ArticleID = int(articleID.Int64)
+	return &u, nil
+}
+
+// AppendChunk stores a chunk at offset, which must be where the upload
+// currently ends; otherwise it returns ErrUploadOffset.
+func (r *AttachmentUploadRepository) AppendChunk(ctx context.Context, id string, offset int64, content []byte, now time.Time) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin attachment upload chunk: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	res, err := tx.ExecContext(ctx, database.ConvertPlaceholders(`
+		UPDATE attachment_upload SET received_size = received_size + ?, change_time = ?
+		WHERE id = ? AND received_size = ? AND received_size + ? <= total_size`),
+		int64(len(content)), now, id, offset, int64(len(content)))
+	if err != nil {
+		return fmt.Errorf("update attachment upload: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrUploadOffset
+	}
+	if _, err := tx.ExecContext(ctx, database.ConvertPlaceholders(`
+		INSERT INTO attachment_upload_chunk (upload_id, chunk_offset, content) VALUES (?, ?, ?)`),
+		id, offset, content); err != nil {
+		return fmt.Errorf("insert attachment upload chunk: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit attachment upload chunk: %w", err)
+	}
+	return nil
+}
+
+// Content returns the bytes received so far, in order.
+func (r *AttachmentUploadRepository) Content(ctx context.Context, id string) ([]byte, error) {
+	rows, err := r.db.QueryContext(ctx, database.ConvertPlaceholders(`
+		SELECT content FROM attachment_upload_chunk WHERE upload_id = ? ORDER BY chunk_offset`), id)
+	if err != nil {
+		return nil, fmt.Errorf("query attachment upload chunks: %w", err)
+	}
+	defer rows.Close()
+
+	var content []byte
+	for rows.Next() {
+		var chunk []byte
+		if err := rows.Scan(&chunk); err != nil {
+			return nil, fmt.Errorf("scan attachment upload chunk: %w", err)
+		}
+		content = append(content, chunk...)
+	}
+	return content, rows.Err()
+}
+
+// Delete removes an upload and its chunks.
+func (r *AttachmentUploadRepository) Delete(ctx context.Context, id string) error {
+	if _, err := r.db.ExecContext(ctx, database.ConvertPlaceholders(
+		"DELETE FROM attachment_upload_chunk WHERE upload_id = ?"), id); err != nil {
+		return fmt.Errorf("delete attachment upload chunks: %w", err)
+	}
+	if _, err := r.db.ExecContext(ctx, database.ConvertPlaceholders(
+		"DELETE FROM attachment_upload WHERE id = ?"), id); err != nil {
+		return fmt.Errorf("delete attachment upload: %w", err)
+	}
+	return nil
+}
+
+// DeleteStale removes the uploads that did not change since the given time
+// and returns how many.
+func (r *AttachmentUploadRepository) DeleteStale(ctx context.Context, before time.Time) (int, error) {
+	rows, err := r.db.QueryContext(ctx, database.ConvertPlaceholders(
+		"SELECT id FROM attachment_upload WHERE change_time < ?"), before)
+	if err != nil {
+		return 0, fmt.Errorf("query stale attachment uploads: %w", err)
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("scan stale attachment upload: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	for i, id := range ids {
+		if err := r.Delete(ctx, id); err != nil {
+			return i, err
+		}
+	}
+	return len(ids), nil
+}
+
+// TicketAttachmentSize returns how many bytes the attachments of a ticket
+// add up to.
+func (r *AttachmentUploadRepository) TicketAttachmentSize(ctx context.Context, ticketID int) (int64, error) {
+	rows, err := r.db.QueryContext(ctx, database.ConvertPlaceholders(`
+		SELECT COALESCE(att.content_size, '') FROM article_data_mime_attachment att
+		JOIN article a ON a.id = att.article_id
+		WHERE a.ticket_id = ?`), ticketID)
+	if err != nil {
+		return 0, fmt.Errorf("query ticket attachment sizes: %w", err)
+	}
+	defer rows.Close()
+
+	var total int64
+	for rows.Next() {
+		var size string
+		if err := rows.Scan(&size); err != nil {
+			return 0, fmt.Errorf("scan ticket attachment size: %w", err)
+		}
+		// content_size is a string column, as in OTRS
+		if n, err := strconv.ParseInt(strings.TrimSpace(size), 10, 64); err == nil {
+			total += n
+		}
+	}
+	return total, rows.Err()
+}
@@ -0,0 +1,337 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/models"
+)
+
+const autoResponseSelect = `
+	SELECT ar.id, ar.name, ar.type_id, COALESCE(art.name, ''), ar.system_address_id,
+	       COALESCE(ar.text0, ''), COALESCE(ar.text1, ''), COALESCE(ar.content_type, ''),
+	       COALESCE(ar.comments, ''), ar.valid_id, ar.create_time, ar.create_by, ar.change_time, ar.change_by
+	FROM auto_response ar
+	LEFT JOIN auto_response_type art ON art.id = ar.type_id`
+
+// AutoResponseTicket holds the ticket and customer values auto responses
+// are rendered with.
+type AutoResponseTicket struct {
+	TicketNumber      string
+	Title             string
+	QueueName         string
+	CustomerUserID    string
+	CustomerID        string
+	CustomerFirstname string
+	CustomerLastname  string
+	CustomerEmail     string
+}
+
+// AutoResponseRepository handles database operations for auto responses
+// and their queue assignments.
+type AutoResponseRepository struct {
+	db *sql.DB
+}
+
+// NewAutoResponseRepository creates a new auto response repository.
+func NewAutoResponseRepository(db *sql.DB) *AutoResponseRepository {
+	return &AutoResponseRepository{db: db}
+}
+
+// List returns all auto responses ordered by name, with their queues.
+func (r *AutoResponseRepository) List(ctx context.Context) ([]*models.AutoResponse, error) {
+	responses, err := r.query(ctx, autoResponseSelect+" ORDER BY ar.name")
+	if err != nil {
+		return nil, err
+	}
+	rows, err := r.db.QueryContext(ctx, "SELECT auto_response_id, queue_id FROM queue_auto_response ORDER BY queue_id")
+	if err != nil {
+		return nil, fmt.Errorf("query queue auto responses: %w", err)
+	}
+	defer rows.Close()
+
+	byID := make(map[int]*models.AutoResponse, len(responses))
+	for _, ar := range responses {
+		byID[ar.ID] = ar
+	}
+	for rows.Next() {
+		var id, queueID int
+		if err := rows.Scan(&id, &queueID); err != nil {
+			return nil, fmt.Errorf("scan queue auto response: %w", err)
+		}
+		if ar := byID[id]; ar != nil {
+			ar.QueueIDs = append(ar.QueueIDs, queueID)
+		}
+	}
+	return responses, rows.Err()
+}
+
+// Get returns an auto response with its queues, or nil if it does not exist.
+func (r *AutoResponseRepository) Get(ctx context.Context, id int) (*models.AutoResponse, error) {
+	ar, err := scanAutoResponse(r.db.QueryRowContext(ctx, database.ConvertPlaceholders(autoResponseSelect+" WHERE ar.id = ?"), id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query auto response: %w", err)
+	}
+	rows, err := r.db.QueryContext(ctx, database.ConvertPlaceholders(
+		"SELECT queue_id FROM queue_auto_response WHERE auto_response_id = ? ORDER BY queue_id"), id)
+	if err != nil {
+		return nil, fmt.Errorf("query auto response queues: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var queueID int
+		if err := rows.Scan(&queueID); err != nil {
+			return nil, fmt.Errorf("scan auto response queue: %w", err)
+		}
+		ar.QueueIDs = append(ar.QueueIDs, queueID)
+	}
+	return ar, rows.Err()
+}
+
+// ListForQueue returns the auto responses assigned to a queue.
+func (r *AutoResponseRepository) ListForQueue(ctx context.Context, queueID int) ([]*models.AutoResponse, error) {
+	return r.query(ctx, autoResponseSelect+`
+		JOIN queue_auto_response qar ON qar.auto_response_id = ar.id
+		WHERE qar.queue_id = ?
+		ORDER BY ar.type_id`, queueID)
+}
+
+// ForQueue returns the valid auto response of a type assigned to a queue,
+// or nil if there is none.
+func (r *AutoResponseRepository) ForQueue(ctx context.Context, queueID, typeID int) (*models.AutoResponse, error) {
+	responses, err := r.query(ctx, autoResponseSelect+`
+		JOIN queue_auto_response qar ON qar.auto_response_id = ar.id
+		WHERE qar.queue_id = ? AND ar.type_id = ? AND ar.valid_id = 1
+		ORDER BY ar.id`, queueID, typeID)
+	if err != nil || len(responses) == 0 {
+		return nil, err
+	}
+	return responses[0], nil
+}
+
+func (r *AutoResponseRepository) query(ctx context.Context, query string, args ...interface{}) ([]*models.AutoResponse, error) {
+	rows, err := r.db.QueryContext(ctx, database.ConvertPlaceholders(query), args...)
+	if err != nil {
+		return nil, fmt.Errorf("query auto responses: %w", err)
+	}
+	defer rows.Close()
+
+	responses := []*models.AutoResponse{}
+	for rows.Next() {
+		ar, err := scanAutoResponse(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan auto response: %w", err)
+		}
+		responses = append(responses, ar)
+	}
+	return responses, rows.Err()
+}
+
+// NameExists reports whether another auto response already uses the name.
+func (r *AutoResponseRepository) NameExists(ctx context.Context, name string, excludeID int) (bool, error) {
+	var count int
+	if err := r.db.QueryRowContext(ctx, database.ConvertPlaceholders(
+		"SELECT COUNT(*) FROM auto_response WHERE name = ? AND id <> ?"), name, excludeID).Scan(&count); err != nil {
+		return false, fmt.Errorf("check auto response name: %w", err)
+	}
+	return count > 0, nil
+}
+
+// Create stores a new auto response and sets its ID.
+func (r *AutoResponseRepository) Create(ctx context.Context, ar *models.AutoResponse) error {
+	id, err := database.GetAdapter().InsertWithReturning(r.db, database.ConvertPlaceholders(`
+		INSERT INTO auto_response (name, text0, text1, type_id, system_address_id, content_type, comments,
+			valid_id, create_time, create_by, change_time, change_by)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id`),
+		ar.Name, ar.Subject, ar.Body, ar.TypeID, ar.SystemAddressID, ar.ContentType, nullString(ar.Comments),
+		ar.ValidID, ar.CreateTime, ar.CreateBy, ar.ChangeTime, ar.ChangeBy)
+	if err != nil {
+		return fmt.Errorf("insert auto response: %w", err)
+	}
+	ar.ID = int(id)
+	return nil
+}
+
+// Update stores the changes to an auto response. It returns sql.ErrNoRows
+// when the auto response does not exist.
+func (r *AutoResponseRepository) Update(ctx context.Context, ar *models.AutoResponse) error {
+	result, err := r.db.ExecContext(ctx, database.ConvertPlaceholders(`
+		UPDATE auto_response
+		SET name = ?, text0 = ?, text1 = ?, type_id = ?, system_address_id = ?, content_type = ?, comments = ?,
+		    valid_id = ?, change_time = ?, change_by = ?
+		WHERE id = ?`),
+		ar.Name, ar.Subject, ar.Body, ar.TypeID, ar.SystemAddressID, ar.ContentType, nullString(ar.Comments),
+		ar.ValidID, ar.ChangeTime, ar.ChangeBy, ar.ID)
+	if err != nil {
+		return fmt.Errorf("update auto response: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// Delete deletes an auto response together with its queue assignments,
+// reporting whether it existed.
+func (r *AutoResponseRepository) Delete(ctx context.Context, id int) (bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("begin auto response delete: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(ctx, database.ConvertPlaceholders(
+		"DELETE FROM queue_auto_response WHERE auto_response_id = ?"), id); err != nil {
+		return false, fmt.Errorf("delete auto response queues: %w", err)
+	}
+	result, err := tx.ExecContext(ctx, database.ConvertPlaceholders("DELETE FROM auto_response WHERE id = ?"), id)
+	if err != nil {
+		return false, fmt.Errorf("delete auto response: %w", err)
+	}
+	n, _ := result.RowsAffected()
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("commit auto response delete: %w", err)
+	}
+	return n > 0, nil
+}
+
+// ReplaceQueueResponses replaces the auto responses assigned to a queue.
+func (r *AutoResponseRepository) ReplaceQueueResponses(ctx context.Context, queueID int, ids []int, userID int, now time.Time) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin queue auto response update: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(ctx, database.ConvertPlaceholders(
+		"DELETE FROM queue_auto_response WHERE queue_id = ?"), queueID); err != nil {
+		return fmt.Errorf("delete queue auto responses: %w", err)
+	}
+	insert := database.ConvertPlaceholders(`
+		INSERT INTO queue_auto_response (queue_id, auto_response_id, create_time, create_by, change_time, change_by)
+		VALUES (?, ?, ?, ?, ?, ?)`)
+	for _, id := range ids {
+		if _, err := tx.ExecContext(ctx, insert, queueID, id, now, userID, now, userID); err != nil {
+			return fmt.Errorf("insert queue auto response: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit queue auto response update: %w", err)
+	}
+	return nil
+}
+
+// ListTypes returns the valid auto response types.
+func (r *AutoResponseRepository) ListTypes(ctx context.Context) ([]models.AutoResponseType, error) {
+	rows, err := r.db.QueryContext(ctx,
+		"SELECT id, name, COALESCE(comments, '') FROM auto_response_type WHERE valid_id = 1 ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("query auto response types: %w", err)
+	}
+	defer rows.Close()
+
+	types := []models.AutoResponseType{}
+	for rows.Next() {
+		var t models.AutoResponseType
+		if err := rows.Scan(&t.ID, &t.Name, &t.Comments); err != nil {
+			return nil, fmt.Errorf("scan auto response type: %w", err)
+		}
+		types = append(types, t)
+	}
+	return types, rows.Err()
+}
+
+// QueueExists reports whether the queue exists.
+func (r *AutoResponseRepository) QueueExists(ctx context.Context, queueID int) (bool, error) {
+	return r.exists(ctx, "SELECT COUNT(*) FROM queue WHERE id = ?", queueID)
+}
+
+// SystemAddressExists reports whether the system address exists.
+func (r *AutoResponseRepository) SystemAddressExists(ctx context.Context, id int) (bool, error) {
+	return r.exists(ctx, "SELECT COUNT(*) FROM system_address WHERE id = ?", id)
+}
+
+// IsSystemAddress reports whether the email address is one of the system's
+// own addresses.
+func (r *AutoResponseRepository) IsSystemAddress(ctx context.Context, email string) (bool, error) {
+	return r.exists(ctx, "SELECT COUNT(*) FROM system_address WHERE LOWER(value0) = ?", strings.ToLower(email))
+}
+
+func (r *AutoResponseRepository) exists(ctx context.Context, query string, args ...interface{}) (bool, error) {
+	var count int
+	if err := r.db.QueryRowContext(ctx, database.ConvertPlaceholders(query), args...).Scan(&count); err != nil {
+		return false, fmt.Errorf("check existence: %w", err)
+	}
+	return count > 0, nil
+}
+
+// Sender returns the email address and display name of a valid system
+// address; both are empty when it does not exist or is invalid.
+func (r *AutoResponseRepository) Sender(ctx context.Context, systemAddressID int) (string, string, error) {
+	var email, name sql.NullString
+	err := r.db.QueryRowContext(ctx, database.ConvertPlaceholders(
+		"SELECT value0, value1 FROM system_address WHERE id = ? AND valid_id = 1"), systemAddressID).Scan(&email, &name)
+	if err == sql.ErrNoRows {
+		return "", "", nil
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("query system address: %w", err)
+	}
+	return strings.TrimSpace(email.String), strings.TrimSpace(name.String), nil
+}
+
+// Ticket returns the values of a ticket and its customer user auto
+// responses are rendered with, or nil if the ticket does not exist.
+func (r *AutoResponseRepository) Ticket(ctx context.Context, ticketID int) (*AutoResponseTicket, error) {
+	var t AutoResponseTicket
+	var title, queueName, customerUserID, customerID sql.NullString
+	err := r.db.QueryRowContext(ctx, database.ConvertPlaceholders(`
+		SELECT t.tn, t.title, q.name, t.customer_user_id, t.customer_id
+		FROM ticket t
+		LEFT JOIN queue q ON q.id = t.queue_id
+		WHERE t.id = ?`), ticketID).Scan(&t.TicketNumber, &title, &queueName, &customerUserID, &customerID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query auto response ticket: %w", err)
+	}
+	t.Title, t.QueueName, t.CustomerUserID = title.String, queueName.String, customerUserID.String
+	t.CustomerID = customerID.String
+	if t.CustomerUserID == "" {
+		return &t, nil
+	}
+
+	// customer_user_id holds the login or the email address.
+	var first, last, email sql.NullString
+	err = r.db.QueryRowContext(ctx, database.ConvertPlaceholders(
+		"SELECT first_name, last_name, email FROM customer_user WHERE login = ? OR email = ?"),
+		t.CustomerUserID, t.CustomerUserID).Scan(&first, &last, &email)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("query auto response customer: %w", err)
+	}
+	t.CustomerFirstname, t.CustomerLastname, t.CustomerEmail = first.String, last.String, email.String
+	return &t, nil
+}
+
+func scanAutoResponse(row kbRowScanner) (*models.AutoResponse, error) {
+	var ar models.AutoResponse
+	if err := row.Scan(&ar.ID, &ar.Name, &ar.TypeID, &ar.TypeName, &ar.SystemAddressID, &ar.Subject, &ar.Body,
+		&ar.ContentType, &ar.Comments, &ar.ValidID, &ar.CreateTime, &ar.CreateBy, &ar.ChangeTime, &ar.ChangeBy); err != nil {
+		return nil, err
+	}
+	ar.QueueIDs = []int{}
+	return &ar, nil
+}
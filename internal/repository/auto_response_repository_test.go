@@ -0,0 +1,95 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/testutil"
+)
+
+func TestAutoResponseRepository(t *testing.T) {
+	db := testutil.UseMigratedDB(t)
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+	repo := NewAutoResponseRepository(db)
+
+	types, err := repo.ListTypes(ctx)
+	require.NoError(t, err)
+	assert.Len(t, types, 5)
+
+	reply := &models.AutoResponse{Name: "Thanks", TypeID: models.AutoResponseTypeReply, SystemAddressID: 1,
+		Subject: "Re: <OTRS_CUSTOMER_SUBJECT>", Body: "We got your mail.", ContentType: "text/plain", ValidID: 1,
+		CreateTime: now, CreateBy: 1, ChangeTime: now, ChangeBy: 1}
+	require.NoError(t, repo.Create(ctx, reply))
+	require.NotZero(t, reply.ID)
+	reject := &models.AutoResponse{Name: "Closed", TypeID: models.AutoResponseTypeReject, SystemAddressID: 2,
+		Subject: "Rejected", Body: "Please open a new ticket.", ContentType: "text/plain", ValidID: 2,
+		CreateTime: now, CreateBy: 1, ChangeTime: now, ChangeBy: 1}
+	require.NoError(t, repo.Create(ctx, reject))
+
+	taken, err := repo.NameExists(ctx, "Thanks", 0)
+	require.NoError(t, err)
+	assert.True(t, taken)
+	taken, err = repo.NameExists(ctx, "Thanks", reply.ID)
+	require.NoError(t, err)
+	assert.False(t, taken, "renaming to its own name is fine")
+
+	require.NoError(t, repo.ReplaceQueueResponses(ctx, 1, []int{reply.ID, reject.ID}, 1, now))
+	require.NoError(t, repo.ReplaceQueueResponses(ctx, 2, []int{reply.ID}, 1, now))
+
+	loaded, err := repo.Get(ctx, reply.ID)
+	require.NoError(t, err)
+	require.NotNil(t, loaded)
+	assert.Equal(t, "auto reply", loaded.TypeName)
+	assert.Equal(t, "Re: <OTRS_CUSTOMER_SUBJECT>", loaded.Subject)
+	assert.Equal(t, []int{1, 2}, loaded.QueueIDs)
+	missing, err := repo.Get(ctx, 999)
+	require.NoError(t, err)
+	assert.Nil(t, missing)
+
+	all, err := repo.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, all, 2)
+	assert.Equal(t, "Closed", all[0].Name)
+	assert.Equal(t, []int{1}, all[0].QueueIDs)
+
+	forQueue, err := repo.ForQueue(ctx, 1, models.AutoResponseTypeReply)
+	require.NoError(t, err)
+	require.NotNil(t, forQueue)
+	assert.Equal(t, reply.ID, forQueue.ID)
+	forQueue, err = repo.ForQueue(ctx, 1, models.AutoResponseTypeReject)
+	require.NoError(t, err)
+	assert.Nil(t, forQueue, "invalid auto responses are not sent")
+
+	reply.Body = "Updated"
+	require.NoError(t, repo.Update(ctx, reply))
+	assert.Equal(t, sql.ErrNoRows, repo.Update(ctx, &models.AutoResponse{ID: 999, TypeID: 1, SystemAddressID: 1}))
+
+	require.NoError(t, repo.ReplaceQueueResponses(ctx, 1, nil, 1, now))
+	queued, err := repo.ListForQueue(ctx, 1)
+	require.NoError(t, err)
+	assert.Empty(t, queued)
+
+	deleted, err := repo.Delete(ctx, reply.ID)
+	require.NoError(t, err)
+	assert.True(t, deleted)
+	deleted, err = repo.Delete(ctx, reply.ID)
+	require.NoError(t, err)
+	assert.False(t, deleted)
+	queued, err = repo.ListForQueue(ctx, 2)
+	require.NoError(t, err)
+	assert.Empty(t, queued, "queue links are removed with the auto response")
+
+	own, err := repo.IsSystemAddress(ctx, "Postmaster@GoatFlow.local")
+	require.NoError(t, err)
+	assert.True(t, own)
+	email, _, err := repo.Sender(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "postmaster@goatflow.local", email)
+}
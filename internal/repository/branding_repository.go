@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/models"
+)
+
+// BrandingRepository stores the images of the branding.
+type BrandingRepository struct {
+	db *sql.DB
+}
+
+// NewBrandingRepository creates a new branding repository.
+func NewBrandingRepository(db *sql.DB) *BrandingRepository {
+	return &BrandingRepository{db: db}
+}
+
+// GetAsset returns an asset with its content, or nil if the scope has none
+// of the kind.
+func (r *BrandingRepository) GetAsset(ctx context.Context, scope, kind string) (*models.BrandingAsset, error) {
+	var a models.BrandingAsset
+	err := r.db.QueryRowContext(ctx, database.ConvertPlaceholders(`
+		SELECT id, scope, kind, filename, content_type, content_size, checksum, content, create_time, create_by
+		FROM branding_asset WHERE scope = ? AND kind = ?`), scope, kind).Scan(
+		&a.ID, &a.Scope, &a.Kind, &a.FileName, &a.ContentType, &a.Size, &a.Checksum, &a.Content, &a.CreateTime, &a.CreateBy)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query branding asset: %w", err)
+	}
+	return &a, nil
+}
+
+// ListAssets returns the assets of the given scopes without their content.
+func (r *BrandingRepository) ListAssets(ctx context.Context, scopes ...string) ([]*models.BrandingAsset, error) {
+	if len(scopes) == 0 {
+		return []*models.BrandingAsset{}, nil
+	}
+	placeholders := make([]string, len(scopes))
+	args := make([]interface{}, len(scopes))
+	for i, scope := range scopes {
+		placeholders[i], args[i] = "?", scope
+	}
+	query := `
+		SELECT id, scope, kind, filename, content_type, content_size, checksum, create_time, create_by
+		FROM branding_asset WHERE scope IN (` + strings.Join(placeholders, ", ") + `) ORDER BY scope, kind`
+	rows, err := r.db.QueryContext(ctx, database.ConvertPlaceholders(query), args...)
+	if err != nil {
+		return nil, fmt.Errorf("query branding assets: %w", err)
+	}
+	defer rows.Close()
+
+	assets := []*models.BrandingAsset{}
+	for rows.Next() {
+		var a models.BrandingAsset
+		if err := rows.Scan(&a.ID, &a.Scope, &a.Kind, &a.FileName, &a.ContentType, &a.Size, &a.Checksum,
+			&a.CreateTime, &a.CreateBy); err != nil {
+			return nil, fmt.Errorf("scan branding asset: %w", err)
+		}
+		assets = append(assets, &a)
+	}
+	return assets, rows.Err()
+}
+
+// SaveAsset stores an asset, replacing the scope's asset of the same kind.
+func (r *BrandingRepository) SaveAsset(ctx context.Context, a *models.BrandingAsset) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(ctx, database.ConvertPlaceholders(
+		"DELETE FROM branding_asset WHERE scope = ? AND kind = ?"), a.Scope, a.Kind); err != nil {
+		return fmt.Errorf("replace branding asset: %w", err)
+	}
+	id, err := database.GetAdapter().InsertWithReturningTx(tx, database.ConvertPlaceholders(`
+		INSERT INTO branding_asset (scope, kind, filename, content_type, content_size, checksum, content,
+			create_time, create_by)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?) RETURNING id`),
+		a.Scope, a.Kind, a.FileName, a.ContentType, a.Size, a.Checksum, a.Content, a.CreateTime, a.CreateBy)
+	if err != nil {
+		return fmt.Errorf("insert branding asset: %w", err)
+	}
+	a.ID = int(id)
+	return tx.Commit()
+}
+
+// DeleteAsset removes the scope's asset of a kind.
+func (r *BrandingRepository) DeleteAsset(ctx context.Context, scope, kind string) (bool, error) {
+	res, err := r.db.ExecContext(ctx, database.ConvertPlaceholders(
+		"DELETE FROM branding_asset WHERE scope = ? AND kind = ?"), scope, kind)
+	if err != nil {
+		return false, fmt.Errorf("delete branding asset: %w", err)
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+// TenantExists reports whether a customer company exists.
+func (r *BrandingRepository) TenantExists(ctx context.Context, customerID string) (bool, error) {
+	var n int
+	err := r.db.QueryRowContext(ctx, database.ConvertPlaceholders(
+		"SELECT COUNT(*) FROM customer_company WHERE customer_id = ?"), customerID).Scan(&n)
+	if err != nil {
+		return false, fmt.Errorf("query customer company: %w", err)
+	}
+	return n > 0, nil
+}
@@ -0,0 +1,62 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/testutil"
+)
+
+func TestBrandingRepository(t *testing.T) {
+	db := testutil.UseMigratedDB(t)
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+	repo := NewBrandingRepository(db)
+
+	a, err := repo.GetAsset(ctx, "", models.BrandingAssetLogo)
+	require.NoError(t, err)
+	assert.Nil(t, a)
+
+	logo := &models.BrandingAsset{Kind: models.BrandingAssetLogo, FileName: "logo.png", ContentType: "image/png",
+		Size: 3, Checksum: "abc", Content: []byte{1, 2, 3}, CreateTime: now, CreateBy: 1}
+	require.NoError(t, repo.SaveAsset(ctx, logo))
+	assert.NotZero(t, logo.ID)
+	replaced := &models.BrandingAsset{Kind: models.BrandingAssetLogo, FileName: "new.png", ContentType: "image/png",
+		Size: 2, Checksum: "def", Content: []byte{4, 5}, CreateTime: now, CreateBy: 1}
+	require.NoError(t, repo.SaveAsset(ctx, replaced))
+	require.NoError(t, repo.SaveAsset(ctx, &models.BrandingAsset{Scope: "acme", Kind: models.BrandingAssetFavicon,
+		FileName: "favicon.ico", ContentType: "image/x-icon", Size: 1, Checksum: "ghi", Content: []byte{6},
+		CreateTime: now, CreateBy: 1}))
+
+	a, err = repo.GetAsset(ctx, "", models.BrandingAssetLogo)
+	require.NoError(t, err)
+	require.NotNil(t, a)
+	assert.Equal(t, "new.png", a.FileName)
+	assert.Equal(t, []byte{4, 5}, a.Content)
+
+	assets, err := repo.ListAssets(ctx, "", "acme")
+	require.NoError(t, err)
+	require.Len(t, assets, 2)
+	assert.Equal(t, "", assets[0].Scope)
+	assert.Equal(t, "acme", assets[1].Scope)
+	assert.Nil(t, assets[1].Content, "lists omit the content")
+	assets, err = repo.ListAssets(ctx, "acme")
+	require.NoError(t, err)
+	assert.Len(t, assets, 1)
+
+	deleted, err := repo.DeleteAsset(ctx, "acme", models.BrandingAssetFavicon)
+	require.NoError(t, err)
+	assert.True(t, deleted)
+	deleted, err = repo.DeleteAsset(ctx, "acme", models.BrandingAssetFavicon)
+	require.NoError(t, err)
+	assert.False(t, deleted)
+
+	exists, err := repo.TenantExists(ctx, "acme")
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
@@ -0,0 +1,510 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/models"
+)
+
+const calendarSelect = `
+	SELECT id, group_id, name, color, salt_string, valid_id, create_time, create_by, change_time, change_by
+	FROM calendar`
+
+const appointmentSelect = `
+	SELECT a.id, COALESCE(a.parent_id, 0), a.calendar_id, a.unique_id, a.title,
+	       COALESCE(a.description, ''), COALESCE(a.location, ''), a.start_time, a.end_time,
+	       COALESCE(a.all_day, 0), a.create_time, COALESCE(a.create_by, 0), a.change_time, COALESCE(a.change_by, 0)
+	FROM calendar_appointment a`
+
+// CalendarRepository handles database operations for team calendars and
+// their appointments. It uses the OTRS calendar tables, so calendars and
+// appointments created by OTRS are available too.
+type CalendarRepository struct {
+	db *sql.DB
+}
+
+// NewCalendarRepository creates a new calendar repository.
+func NewCalendarRepository(db *sql.DB) *CalendarRepository {
+	return &CalendarRepository{db: db}
+}
+
+// ListCalendars returns calendars ordered by name. With groupIDs non-nil
+// only calendars of those groups are returned; validOnly skips invalid
+// calendars.
+func (r *CalendarRepository) ListCalendars(ctx context.Context, groupIDs []int, validOnly bool) ([]*models.Calendar, error) {
+	var where []string
+	var args []interface{}
+	if groupIDs != nil {
+		if len(groupIDs) == 0 {
+			return []*models.Calendar{}, nil
+		}
+		where = append(where, "group_id IN ("+placeholderList(len(groupIDs))+")")
+		for _, id := range groupIDs {
+			args = append(args, id)
+		}
+	}
+	if validOnly {
+		where = append(where, "valid_id = 1")
+	}
+	query := calendarSelect
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	rows, err := r.db.QueryContext(ctx, database.ConvertPlaceholders(query+" ORDER BY name"), args...)
+	if err != nil {
+		return nil, fmt.Errorf("query calendars: %w", err)
+	}
+	defer rows.Close()
+
+	calendars := []*models.Calendar{}
+	for rows.Next() {
+		cal, err := scanCalendar(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan calendar: %w", err)
+		}
+		calendars = append(calendars, cal)
+	}
+	return calendars, rows.Err()
+}
+
+// GetCalendar returns a calendar, or nil if it does not exist.
+func (r *CalendarRepository) GetCalendar(ctx context.Context, id int64) (*models.Calendar, error) {
+	cal, err := scanCalendar(r.db.QueryRowContext(ctx, database.ConvertPlaceholders(calendarSelect+" WHERE id = ?"), id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query calendar: %w", err)
+	}
+	return cal, nil
+}
+
+// CalendarNameExists reports whether another calendar already uses the name.
+func (r *CalendarRepository) CalendarNameExists(ctx context.Context, name string, excludeID int64) (bool, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx, database.ConvertPlaceholders(
+		"SELECT COUNT(*) FROM calendar WHERE name = ? AND id <> ?"), name, excludeID).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("check calendar name: %w", err)
+	}
+	return count > 0, nil
+}
+
+// CreateCalendar inserts a calendar, setting its ID.
+func (r *CalendarRepository) CreateCalendar(ctx context.Context, cal *models.Calendar) error {
+	id, err := database.GetAdapter().InsertWithReturning(r.db, database.ConvertPlaceholders(`
+		INSERT INTO calendar (group_id, name, salt_string, color, valid_id, create_time, create_by, change_time, change_by)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id`),
+		cal.GroupID, cal.Name, cal.SaltString, cal.Color, cal.ValidID, cal.CreateTime, cal.CreateBy, cal.ChangeTime, cal.ChangeBy)
+	if err != nil {
+		return fmt.Errorf("insert calendar: %w", err)
+	}
+	cal.ID = id
+	return nil
+}
+
+// UpdateCalendar stores changes to a calendar. It returns sql.ErrNoRows when
+// the calendar does not exist.
+func (r *CalendarRepository) UpdateCalendar(ctx context.Context, cal *models.Calendar) error {
+	result, err := r.db.ExecContext(ctx, database.ConvertPlaceholders(`
+		UPDATE calendar
+		SET group_id = ?, name = ?, color = ?, valid_id = ?, change_time = ?, change_by = ?
+		WHERE id = ?`),
+		cal.GroupID, cal.Name, cal.Color, cal.ValidID, cal.ChangeTime, cal.ChangeBy, cal.ID)
+	if err != nil {
+		return fmt.Errorf("update calendar: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// SetCalendarSalt replaces the salt keying a calendar's feed tokens.
+func (r *CalendarRepository) SetCalendarSalt(ctx context.Context, id int64, salt string) error {
+	result, err := r.db.ExecContext(ctx, database.ConvertPlaceholders(
+		"UPDATE calendar SET salt_string = ? WHERE id = ?"), salt, id)
+	if err != nil {
+		return fmt.Errorf("update calendar salt: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// DeleteCalendar deletes a calendar with its appointments, reporting
+// whether it existed.
+func (r *CalendarRepository) DeleteCalendar(ctx context.Context, id int64) (bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("begin calendar delete: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck // No-op after commit
+
+	appointments := "SELECT id FROM calendar_appointment WHERE calendar_id = ?"
+	for _, stmt := range []string{
+		"DELETE FROM calendar_appointment_attendee WHERE appointment_id IN (" + appointments + ")",
+		"DELETE FROM calendar_appointment_ticket_link WHERE appointment_id IN (" + appointments + ")",
+		"DELETE FROM calendar_appointment_plugin WHERE appointment_id IN (" + appointments + ")",
+		"DELETE FROM calendar_appointment_ticket WHERE calendar_id = ?",
+		// Occurrences of recurring appointments reference their parent
+		"DELETE FROM calendar_appointment WHERE calendar_id = ? AND parent_id IS NOT NULL",
+		"DELETE FROM calendar_appointment WHERE calendar_id = ?",
+	} {
+		if _, err := tx.ExecContext(ctx, database.ConvertPlaceholders(stmt), id); err != nil {
+			return false, fmt.Errorf("delete calendar appointments: %w", err)
+		}
+	}
+	result, err := tx.ExecContext(ctx, database.ConvertPlaceholders("DELETE FROM calendar WHERE id = ?"), id)
+	if err != nil {
+		return false, fmt.Errorf("delete calendar: %w", err)
+	}
+	n, _ := result.RowsAffected()
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("commit calendar delete: %w", err)
+	}
+	return n > 0, nil
+}
+
+// ListAppointments returns the appointments matching the filter ordered by
+// start time, with their attendees and tickets.
+func (r *CalendarRepository) ListAppointments(ctx context.Context, filter models.CalendarAppointmentFilter) ([]*models.CalendarAppointment, error) {
+	if len(filter.CalendarIDs) == 0 {
+		return []*models.CalendarAppointment{}, nil
+	}
+	where := []string{"a.calendar_id IN (" + placeholderList(len(filter.CalendarIDs)) + ")"}
+	var args []interface{}
+	for _, id := range filter.CalendarIDs {
+		args = append(args, id)
+	}
+	if !filter.From.IsZero() {
+		where = append(where, "a.end_time > ?")
+		args = append(args, filter.From)
+	}
+	if !filter.To.IsZero() {
+		where = append(where, "a.start_time < ?")
+		args = append(args, filter.To)
+	}
+	if filter.TicketID > 0 {
+		where = append(where, `(EXISTS (SELECT 1 FROM calendar_appointment_ticket_link l WHERE l.appointment_id = a.id AND l.ticket_id = ?)
+			OR EXISTS (SELECT 1 FROM calendar_appointment_ticket ct WHERE ct.appointment_id = a.id AND ct.ticket_id = ?))`)
+		args = append(args, filter.TicketID, filter.TicketID)
+	}
+	if filter.AttendeeID > 0 {
+		where = append(where, "EXISTS (SELECT 1 FROM calendar_appointment_attendee at WHERE at.appointment_id = a.id AND at.user_id = ?)")
+		args = append(args, filter.AttendeeID)
+	}
+	query := appointmentSelect + " WHERE " + strings.Join(where, " AND ") + " ORDER BY a.start_time, a.id"
+	if filter.Limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", filter.Limit)
+	}
+	rows, err := r.db.QueryContext(ctx, database.ConvertPlaceholders(query), args...)
+	if err != nil {
+		return nil, fmt.Errorf("query appointments: %w", err)
+	}
+	defer rows.Close()
+
+	appointments := []*models.CalendarAppointment{}
+	for rows.Next() {
+		a, err := scanAppointment(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan appointment: %w", err)
+		}
+		appointments = append(appointments, a)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+	if err := r.loadAppointmentDetails(ctx, appointments); err != nil {
+		return nil, err
+	}
+	return appointments, nil
+}
+
+// GetAppointment returns an appointment with its attendees and tickets, or
+// nil if it does not exist.
+func (r *CalendarRepository) GetAppointment(ctx context.Context, id int64) (*models.CalendarAppointment, error) {
+	a, err := scanAppointment(r.db.QueryRowContext(ctx, database.ConvertPlaceholders(appointmentSelect+" WHERE a.id = ?"), id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query appointment: %w", err)
+	}
+	if err := r.loadAppointmentDetails(ctx, []*models.CalendarAppointment{a}); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// CreateAppointment inserts an appointment with its attendees and ticket
+// links, setting its ID.
+func (r *CalendarRepository) CreateAppointment(ctx context.Context, a *models.CalendarAppointment) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin appointment insert: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck // No-op after commit
+
+	id, err := database.GetAdapter().InsertWithReturningTx(tx, database.ConvertPlaceholders(`
+		INSERT INTO calendar_appointment (calendar_id, unique_id, title, description, location,
+			start_time, end_time, all_day, create_time, create_by, change_time, change_by)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id`),
+		a.CalendarID, a.UniqueID, a.Title, a.Description, a.Location,
+		a.StartTime, a.EndTime, boolToSmallint(a.AllDay), a.CreateTime, a.CreateBy, a.ChangeTime, a.ChangeBy)
+	if err != nil {
+		return fmt.Errorf("insert appointment: %w", err)
+	}
+	if err := insertAppointmentDetails(ctx, tx, id, a); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit appointment insert: %w", err)
+	}
+	a.ID = id
+	return nil
+}
+
+// UpdateAppointment stores changes to an appointment and replaces its
+// attendees and ticket links. It returns sql.ErrNoRows when the appointment
+// does not exist.
+func (r *CalendarRepository) UpdateAppointment(ctx context.Context, a *models.CalendarAppointment) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin appointment update: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck // No-op after commit
+
+	result, err := tx.ExecContext(ctx, database.ConvertPlaceholders(`
+		UPDATE calendar_appointment
+		SET calendar_id = ?, title = ?, description = ?, location = ?, start_time = ?, end_time = ?,
+		    all_day = ?, change_time = ?, change_by = ?
+		WHERE id = ?`),
+		a.CalendarID, a.Title, a.Description, a.Location, a.StartTime, a.EndTime,
+		boolToSmallint(a.AllDay), a.ChangeTime, a.ChangeBy, a.ID)
+	if err != nil {
+		return fmt.Errorf("update appointment: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	for _, stmt := range []string{
+		"DELETE FROM calendar_appointment_attendee WHERE appointment_id = ?",
+		"DELETE FROM calendar_appointment_ticket_link WHERE appointment_id = ?",
+	} {
+		if _, err := tx.ExecContext(ctx, database.ConvertPlaceholders(stmt), a.ID); err != nil {
+			return fmt.Errorf("clear appointment details: %w", err)
+		}
+	}
+	if err := insertAppointmentDetails(ctx, tx, a.ID, a); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit appointment update: %w", err)
+	}
+	return nil
+}
+
+// DeleteAppointment deletes an appointment with its occurrences, reporting
+// whether it existed.
+func (r *CalendarRepository) DeleteAppointment(ctx context.Context, id int64) (bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("begin appointment delete: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck // No-op after commit
+
+	ids := "SELECT id FROM calendar_appointment WHERE id = ? OR parent_id = ?"
+	for _, stmt := range []string{
+		"DELETE FROM calendar_appointment_attendee WHERE appointment_id IN (" + ids + ")",
+		"DELETE FROM calendar_appointment_ticket_link WHERE appointment_id IN (" + ids + ")",
+		"DELETE FROM calendar_appointment_plugin WHERE appointment_id IN (" + ids + ")",
+		"DELETE FROM calendar_appointment_ticket WHERE appointment_id IN (" + ids + ")",
+	} {
+		if _, err := tx.ExecContext(ctx, database.ConvertPlaceholders(stmt), id, id); err != nil {
+			return false, fmt.Errorf("delete appointment details: %w", err)
+		}
+	}
+	if _, err := tx.ExecContext(ctx, database.ConvertPlaceholders(
+		"DELETE FROM calendar_appointment WHERE parent_id = ?"), id); err != nil {
+		return false, fmt.Errorf("delete appointment occurrences: %w", err)
+	}
+	result, err := tx.ExecContext(ctx, database.ConvertPlaceholders("DELETE FROM calendar_appointment WHERE id = ?"), id)
+	if err != nil {
+		return false, fmt.Errorf("delete appointment: %w", err)
+	}
+	n, _ := result.RowsAffected()
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("commit appointment delete: %w", err)
+	}
+	return n > 0, nil
+}
+
+// CountTickets returns how many of the tickets exist.
+func (r *CalendarRepository) CountTickets(ctx context.Context, ids []int64) (int, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	var count int
+	err := r.db.QueryRowContext(ctx, database.ConvertPlaceholders(
+		"SELECT COUNT(*) FROM ticket WHERE id IN ("+placeholderList(len(ids))+")"), args...).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("count tickets: %w", err)
+	}
+	return count, nil
+}
+
+// CountValidUsers returns how many of the agents exist and are valid.
+func (r *CalendarRepository) CountValidUsers(ctx context.Context, ids []int) (int, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	var count int
+	err := r.db.QueryRowContext(ctx, database.ConvertPlaceholders(
+		"SELECT COUNT(*) FROM users WHERE valid_id = 1 AND id IN ("+placeholderList(len(ids))+")"), args...).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("count users: %w", err)
+	}
+	return count, nil
+}
+
+// loadAppointmentDetails sets the attendees and tickets of appointments.
+// Tickets linked by ticket appointment rules are included.
+func (r *CalendarRepository) loadAppointmentDetails(ctx context.Context, appointments []*models.CalendarAppointment) error {
+	if len(appointments) == 0 {
+		return nil
+	}
+	byID := make(map[int64]*models.CalendarAppointment, len(appointments))
+	args := make([]interface{}, len(appointments))
+	for i, a := range appointments {
+		a.AttendeeIDs = []int{}
+		a.Tickets = []models.CalendarAppointmentTicket{}
+		byID[a.ID] = a
+		args[i] = a.ID
+	}
+	in := placeholderList(len(appointments))
+
+	rows, err := r.db.QueryContext(ctx, database.ConvertPlaceholders(`
+		SELECT appointment_id, user_id FROM calendar_appointment_attendee
+		WHERE appointment_id IN (`+in+`)
+		ORDER BY appointment_id, user_id`), args...)
+	if err != nil {
+		return fmt.Errorf("query appointment attendees: %w", err)
+	}
+	for rows.Next() {
+		var appointmentID int64
+		var userID int
+		if err := rows.Scan(&appointmentID, &userID); err != nil {
+			rows.Close()
+			return fmt.Errorf("scan appointment attendee: %w", err)
+		}
+		byID[appointmentID].AttendeeIDs = append(byID[appointmentID].AttendeeIDs, userID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	rows, err = r.db.QueryContext(ctx, database.ConvertPlaceholders(`
+		SELECT l.appointment_id, t.id, t.tn, COALESCE(t.title, '')
+		FROM (
+			SELECT appointment_id, ticket_id FROM calendar_appointment_ticket_link WHERE appointment_id IN (`+in+`)
+			UNION
+			SELECT appointment_id, ticket_id FROM calendar_appointment_ticket WHERE appointment_id IN (`+in+`)
+		) l
+		JOIN ticket t ON t.id = l.ticket_id
+		ORDER BY l.appointment_id, t.id`), append(args, args...)...)
+	if err != nil {
+		return fmt.Errorf("query appointment tickets: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var appointmentID int64
+		var t models.CalendarAppointmentTicket
+		if err := rows.Scan(&appointmentID, &t.ID, &t.Number, &t.Title); err != nil {
+			return fmt.Errorf("scan appointment ticket: %w", err)
+		}
+		byID[appointmentID].Tickets = append(byID[appointmentID].Tickets, t)
+	}
+	return rows.Err()
+}
+
+func insertAppointmentDetails(ctx context.Context, tx *sql.Tx, id int64, a *models.CalendarAppointment) error {
+	seenUsers := make(map[int]bool, len(a.AttendeeIDs))
+	for _, userID := range a.AttendeeIDs {
+		if seenUsers[userID] {
+			continue
+		}
+		seenUsers[userID] = true
+		if _, err := tx.ExecContext(ctx, database.ConvertPlaceholders(
+			"INSERT INTO calendar_appointment_attendee (appointment_id, user_id) VALUES (?, ?)"), id, userID); err != nil {
+			return fmt.Errorf("insert appointment attendee: %w", err)
+		}
+	}
+	seenTickets := make(map[int64]bool, len(a.Tickets))
+	for _, ticketID := range a.TicketIDs() {
+		if seenTickets[ticketID] {
+			continue
+		}
+		seenTickets[ticketID] = true
+		if _, err := tx.ExecContext(ctx, database.ConvertPlaceholders(`
+			INSERT INTO calendar_appointment_ticket_link (appointment_id, ticket_id, create_time, create_by)
+			VALUES (?, ?, ?, ?)`), id, ticketID, a.ChangeTime, a.ChangeBy); err != nil {
+			return fmt.Errorf("insert appointment ticket link: %w", err)
+		}
+	}
+	return nil
+}
+
+func scanCalendar(row kbRowScanner) (*models.Calendar, error) {
+	var cal models.Calendar
+	if err := row.Scan(&cal.ID, &cal.GroupID, &cal.Name, &cal.Color, &cal.SaltString, &cal.ValidID,
+		&cal.CreateTime, &cal.CreateBy, &cal.ChangeTime, &cal.ChangeBy); err != nil {
+		return nil, err
+	}
+	return &cal, nil
+}
+
+func scanAppointment(row kbRowScanner) (*models.CalendarAppointment, error) {
+	var a models.CalendarAppointment
+	var allDay int
+	var createTime, changeTime sql.NullTime
+	if err := row.Scan(&a.ID, &a.ParentID, &a.CalendarID, &a.UniqueID, &a.Title,
+		&a.Description, &a.Location, &a.StartTime, &a.EndTime,
+		&allDay, &createTime, &a.CreateBy, &changeTime, &a.ChangeBy); err != nil {
+		return nil, err
+	}
+	a.AllDay = allDay == 1
+	// OTRS leaves the audit columns of appointments nullable
+	a.CreateTime = nullTimeOr(createTime, a.StartTime)
+	a.ChangeTime = nullTimeOr(changeTime, a.CreateTime)
+	return &a, nil
+}
+
+func nullTimeOr(t sql.NullTime, fallback time.Time) time.Time {
+	if t.Valid {
+		return t.Time
+	}
+	return fallback
+}
+
+func placeholderList(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
@@ -0,0 +1,156 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/testutil"
+)
+
+func TestCalendarRepository(t *testing.T) {
+	db := testutil.UseMigratedDB(t)
+	_, err := db.Exec(`INSERT INTO users (id, login, pw, first_name, last_name, valid_id, create_time, create_by, change_time, change_by)
+		VALUES (1, 'root@localhost', 'x', 'Admin', 'OTRS', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1),
+		       (2, 'ann', 'x', 'Ann', 'Agent', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1),
+		       (3, 'gone', 'x', 'Gone', 'Agent', 2, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+	insertArchiveTestTicket(t, db, 1, 1, now)
+	insertArchiveTestTicket(t, db, 2, 1, now)
+
+	repo := NewCalendarRepository(db)
+	support := &models.Calendar{GroupID: 1, Name: "Support", Color: "#3A87AD", SaltString: "salt", ValidID: 1,
+		CreateTime: now, CreateBy: 1, ChangeTime: now, ChangeBy: 1}
+	require.NoError(t, repo.CreateCalendar(ctx, support))
+	require.NotZero(t, support.ID)
+	other := &models.Calendar{GroupID: 2, Name: "Admins", Color: "#FF0000", SaltString: "salt", ValidID: 2,
+		CreateTime: now, CreateBy: 1, ChangeTime: now, ChangeBy: 1}
+	require.NoError(t, repo.CreateCalendar(ctx, other))
+
+	exists, err := repo.CalendarNameExists(ctx, "Support", 0)
+	require.NoError(t, err)
+	assert.True(t, exists)
+	exists, err = repo.CalendarNameExists(ctx, "Support", support.ID)
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	calendars, err := repo.ListCalendars(ctx, nil, false)
+	require.NoError(t, err)
+	assert.Len(t, calendars, 2)
+	calendars, err = repo.ListCalendars(ctx, nil, true)
+	require.NoError(t, err)
+	require.Len(t, calendars, 1)
+	assert.Equal(t, "Support", calendars[0].Name)
+	calendars, err = repo.ListCalendars(ctx, []int{2}, false)
+	require.NoError(t, err)
+	require.Len(t, calendars, 1)
+	assert.Equal(t, "Admins", calendars[0].Name)
+	calendars, err = repo.ListCalendars(ctx, []int{}, false)
+	require.NoError(t, err)
+	assert.Empty(t, calendars)
+
+	other.Name, other.ValidID = "Admin team", 1
+	require.NoError(t, repo.UpdateCalendar(ctx, other))
+	got, err := repo.GetCalendar(ctx, other.ID)
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	assert.Equal(t, "Admin team", got.Name)
+	assert.Equal(t, "salt", got.SaltString)
+	assert.ErrorIs(t, repo.UpdateCalendar(ctx, &models.Calendar{ID: 999, Name: "x"}), sql.ErrNoRows)
+	require.NoError(t, repo.SetCalendarSalt(ctx, other.ID, "pepper"))
+	got, err = repo.GetCalendar(ctx, other.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "pepper", got.SaltString)
+	assert.ErrorIs(t, repo.SetCalendarSalt(ctx, 999, "x"), sql.ErrNoRows)
+	got, err = repo.GetCalendar(ctx, 999)
+	require.NoError(t, err)
+	assert.Nil(t, got)
+
+	count, err := repo.CountTickets(ctx, []int64{1, 2, 99})
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+	count, err = repo.CountValidUsers(ctx, []int{1, 2, 3})
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	standup := &models.CalendarAppointment{
+		CalendarID: support.ID, UniqueID: "uid-1", Title: "Standup", Location: "Room 1",
+		StartTime: now.Add(time.Hour), EndTime: now.Add(90 * time.Minute),
+		AttendeeIDs: []int{2, 1, 2}, Tickets: []models.CalendarAppointmentTicket{{ID: 1}},
+		CreateTime: now, CreateBy: 1, ChangeTime: now, ChangeBy: 1,
+	}
+	require.NoError(t, repo.CreateAppointment(ctx, standup))
+	require.NotZero(t, standup.ID)
+	offsite := &models.CalendarAppointment{
+		CalendarID: other.ID, UniqueID: "uid-2", Title: "Offsite", AllDay: true,
+		StartTime: now.Truncate(24*time.Hour).AddDate(0, 0, 3), EndTime: now.Truncate(24*time.Hour).AddDate(0, 0, 4),
+		CreateTime: now, CreateBy: 1, ChangeTime: now, ChangeBy: 1,
+	}
+	require.NoError(t, repo.CreateAppointment(ctx, offsite))
+
+	a, err := repo.GetAppointment(ctx, standup.ID)
+	require.NoError(t, err)
+	require.NotNil(t, a)
+	assert.Equal(t, "Standup", a.Title)
+	assert.Equal(t, []int{1, 2}, a.AttendeeIDs)
+	require.Len(t, a.Tickets, 1)
+	assert.Equal(t, "20250101000001", a.Tickets[0].Number)
+	assert.Equal(t, "Printer", a.Tickets[0].Title)
+
+	all := []int64{support.ID, other.ID}
+	list, err := repo.ListAppointments(ctx, models.CalendarAppointmentFilter{CalendarIDs: all})
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	assert.Equal(t, "Standup", list[0].Title)
+	assert.True(t, list[1].AllDay)
+	list, err = repo.ListAppointments(ctx, models.CalendarAppointmentFilter{CalendarIDs: all, From: now, To: now.Add(24 * time.Hour)})
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, "Standup", list[0].Title)
+	list, err = repo.ListAppointments(ctx, models.CalendarAppointmentFilter{CalendarIDs: all, TicketID: 1})
+	require.NoError(t, err)
+	assert.Len(t, list, 1)
+	list, err = repo.ListAppointments(ctx, models.CalendarAppointmentFilter{CalendarIDs: all, AttendeeID: 2, Limit: 5})
+	require.NoError(t, err)
+	assert.Len(t, list, 1)
+	list, err = repo.ListAppointments(ctx, models.CalendarAppointmentFilter{})
+	require.NoError(t, err)
+	assert.Empty(t, list)
+
+	a.Title = "Daily standup"
+	a.AttendeeIDs = []int{2}
+	a.Tickets = []models.CalendarAppointmentTicket{{ID: 2}}
+	require.NoError(t, repo.UpdateAppointment(ctx, a))
+	a, err = repo.GetAppointment(ctx, standup.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Daily standup", a.Title)
+	assert.Equal(t, []int{2}, a.AttendeeIDs)
+	require.Len(t, a.Tickets, 1)
+	assert.Equal(t, int64(2), a.Tickets[0].ID)
+	assert.ErrorIs(t, repo.UpdateAppointment(ctx, &models.CalendarAppointment{ID: 999}), sql.ErrNoRows)
+
+	existed, err := repo.DeleteAppointment(ctx, standup.ID)
+	require.NoError(t, err)
+	assert.True(t, existed)
+	assert.Zero(t, countRows(t, db, "SELECT COUNT(*) FROM calendar_appointment_attendee"))
+	assert.Zero(t, countRows(t, db, "SELECT COUNT(*) FROM calendar_appointment_ticket_link"))
+	existed, err = repo.DeleteAppointment(ctx, standup.ID)
+	require.NoError(t, err)
+	assert.False(t, existed)
+
+	existed, err = repo.DeleteCalendar(ctx, other.ID)
+	require.NoError(t, err)
+	assert.True(t, existed)
+	assert.Zero(t, countRows(t, db, "SELECT COUNT(*) FROM calendar_appointment"))
+	got, err = repo.GetCalendar(ctx, other.ID)
+	require.NoError(t, err)
+	assert.Nil(t, got)
+}
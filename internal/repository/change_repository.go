@@ -0,0 +1,274 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/models"
+)
+
+const changePlanSelect = `
+	SELECT p.ticket_id, t.tn, t.title, t.queue_id, COALESCE(ts.name, ''),
+	       p.planned_start, p.planned_end, p.risk,
+	       COALESCE(p.implementation_plan, ''), COALESCE(p.backout_plan, ''),
+	       COALESCE((SELECT ar.status FROM ticket_approval_request ar
+	                 WHERE ar.ticket_id = p.ticket_id ORDER BY ar.id DESC LIMIT 1), ''),
+	       p.create_time, p.create_by, p.change_time, p.change_by
+	FROM ticket_change_plan p
+	JOIN ticket t ON t.id = p.ticket_id
+	LEFT JOIN ticket_state ts ON ts.id = t.ticket_state_id`
+
+// ChangeRepository handles database operations for change plans.
+type ChangeRepository struct {
+	db *sql.DB
+}
+
+// NewChangeRepository creates a new change repository.
+func NewChangeRepository(db *sql.DB) *ChangeRepository {
+	return &ChangeRepository{db: db}
+}
+
+// Get returns the change plan of a ticket, or nil if it has none.
+func (r *ChangeRepository) Get(ctx context.Context, ticketID int) (*models.ChangePlan, error) {
+	row := r.db.QueryRowContext(ctx, database.ConvertPlaceholders(changePlanSelect+" WHERE p.ticket_id = ?"), ticketID)
+	plan, err := scanChangePlan(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get change plan: %w", err)
+	}
+	if err := r.loadItems(ctx, []*models.ChangePlan{plan}); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// List returns the change plans whose window overlaps the filter's range,
+// ordered by planned start.
+func (r *ChangeRepository) List(ctx context.Context, filter models.ChangeCalendarFilter) ([]models.ChangePlan, error) {
+	where := []string{"p.planned_start < ?", "p.planned_end > ?"}
+	args := []interface{}{filter.To, filter.From}
+	if filter.QueueIDs != nil {
+		if len(filter.QueueIDs) == 0 {
+			return make([]models.ChangePlan, 0), nil
+		}
+		placeholders := make([]string, len(filter.QueueIDs))
+		for i, id := range filter.QueueIDs {
+			placeholders[i] = "?"
+			args = append(args, id)
+		}
+		where = append(where, "t.queue_id IN ("+strings.Join(placeholders, ",")+")")
+	}
+	if filter.ServiceID > 0 {
+		where = append(where, "EXISTS (SELECT 1 FROM ticket_change_service cs WHERE cs.ticket_id = p.ticket_id AND cs.service_id = ?)")
+		args = append(args, filter.ServiceID)
+	}
+	if key := models.ChangeConfigItemKey(filter.ConfigItem); key != "" {
+		where = append(where, "EXISTS (SELECT 1 FROM ticket_change_ci cc WHERE cc.ticket_id = p.ticket_id AND cc.ci_key = ?)")
+		args = append(args, key)
+	}
+	if filter.ActiveOnly {
+		where = append(where, `t.ticket_state_id IN (
+			SELECT s.id FROM ticket_state s JOIN ticket_state_type st ON st.id = s.type_id
+			WHERE st.name NOT IN ('closed', 'merged', 'removed'))`)
+	}
+
+	rows, err := r.db.QueryContext(ctx, database.ConvertPlaceholders(
+		changePlanSelect+" WHERE "+strings.Join(where, " AND ")+" ORDER BY p.planned_start, p.ticket_id"), args...)
+	if err != nil {
+		return nil, fmt.Errorf("query change plans: %w", err)
+	}
+	defer rows.Close()
+
+	var plans []*models.ChangePlan
+	for rows.Next() {
+		plan, err := scanChangePlan(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan change plan: %w", err)
+		}
+		plans = append(plans, plan)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if err := r.loadItems(ctx, plans); err != nil {
+		return nil, err
+	}
+
+	result := make([]models.ChangePlan, 0, len(plans))
+	for _, plan := range plans {
+		result = append(result, *plan)
+	}
+	return result, nil
+}
+
+// loadItems fills in the services and configuration items of the plans.
+func (r *ChangeRepository) loadItems(ctx context.Context, plans []*models.ChangePlan) error {
+	if len(plans) == 0 {
+		return nil
+	}
+	byTicket := make(map[int]*models.ChangePlan, len(plans))
+	placeholders := make([]string, len(plans))
+	args := make([]interface{}, len(plans))
+	for i, plan := range plans {
+		plan.ServiceIDs = make([]int, 0)
+		plan.ConfigItems = make([]string, 0)
+		byTicket[plan.TicketID] = plan
+		placeholders[i] = "?"
+		args[i] = plan.TicketID
+	}
+	in := "(" + strings.Join(placeholders, ",") + ")"
+
+	rows, err := r.db.QueryContext(ctx, database.ConvertPlaceholders(
+		"SELECT ticket_id, service_id FROM ticket_change_service WHERE ticket_id IN "+in+" ORDER BY service_id"), args...)
+	if err != nil {
+		return fmt.Errorf("query change services: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var ticketID, serviceID int
+		if err := rows.Scan(&ticketID, &serviceID); err != nil {
+			return fmt.Errorf("scan change service: %w", err)
+		}
+		byTicket[ticketID].ServiceIDs = append(byTicket[ticketID].ServiceIDs, serviceID)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	crows, err := r.db.QueryContext(ctx, database.ConvertPlaceholders(
+		"SELECT ticket_id, ci_name FROM ticket_change_ci WHERE ticket_id IN "+in+" ORDER BY ci_key"), args...)
+	if err != nil {
+		return fmt.Errorf("query change items: %w", err)
+	}
+	defer crows.Close()
+	for crows.Next() {
+		var ticketID int
+		var name string
+		if err := crows.Scan(&ticketID, &name); err != nil {
+			return fmt.Errorf("scan change item: %w", err)
+		}
+		byTicket[ticketID].ConfigItems = append(byTicket[ticketID].ConfigItems, name)
+	}
+	return crows.Err()
+}
+
+// Save creates or replaces the change plan of a ticket in one transaction.
+func (r *ChangeRepository) Save(ctx context.Context, plan *models.ChangePlan, userID int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin change plan: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	now := time.Now()
+	result, err := tx.ExecContext(ctx, database.ConvertPlaceholders(`
+		UPDATE ticket_change_plan
+		SET planned_start = ?, planned_end = ?, risk = ?, implementation_plan = ?, backout_plan = ?,
+			change_time = ?, change_by = ?
+		WHERE ticket_id = ?
+	`), plan.PlannedStart, plan.PlannedEnd, string(plan.Risk), plan.ImplementationPlan, plan.BackoutPlan,
+		now, userID, plan.TicketID)
+	if err != nil {
+		return fmt.Errorf("update change plan: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		if _, err := tx.ExecContext(ctx, database.ConvertPlaceholders(`
+			INSERT INTO ticket_change_plan (ticket_id, planned_start, planned_end, risk, implementation_plan,
+				backout_plan, create_time, create_by, change_time, change_by)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`), plan.TicketID, plan.PlannedStart, plan.PlannedEnd, string(plan.Risk), plan.ImplementationPlan,
+			plan.BackoutPlan, now, userID, now, userID); err != nil {
+			return fmt.Errorf("insert change plan: %w", err)
+		}
+	}
+
+	for _, table := range []string{"ticket_change_service", "ticket_change_ci"} {
+		if _, err := tx.ExecContext(ctx, database.ConvertPlaceholders(
+			"DELETE FROM "+table+" WHERE ticket_id = ?"), plan.TicketID); err != nil {
+			return fmt.Errorf("clear %s: %w", table, err)
+		}
+	}
+	for _, id := range plan.ServiceIDs {
+		if _, err := tx.ExecContext(ctx, database.ConvertPlaceholders(
+			"INSERT INTO ticket_change_service (ticket_id, service_id) VALUES (?, ?)"), plan.TicketID, id); err != nil {
+			return fmt.Errorf("insert change service %d: %w", id, err)
+		}
+	}
+	for _, name := range plan.ConfigItems {
+		if _, err := tx.ExecContext(ctx, database.ConvertPlaceholders(
+			"INSERT INTO ticket_change_ci (ticket_id, ci_key, ci_name) VALUES (?, ?, ?)"),
+			plan.TicketID, models.ChangeConfigItemKey(name), name); err != nil {
+			return fmt.Errorf("insert change item %q: %w", name, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// Delete removes the change plan of a ticket, returning sql.ErrNoRows when it
+// has none.
+func (r *ChangeRepository) Delete(ctx context.Context, ticketID int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin change plan delete: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for _, table := range []string{"ticket_change_service", "ticket_change_ci"} {
+		if _, err := tx.ExecContext(ctx, database.ConvertPlaceholders(
+			"DELETE FROM "+table+" WHERE ticket_id = ?"), ticketID); err != nil {
+			return fmt.Errorf("clear %s: %w", table, err)
+		}
+	}
+	result, err := tx.ExecContext(ctx, database.ConvertPlaceholders(
+		"DELETE FROM ticket_change_plan WHERE ticket_id = ?"), ticketID)
+	if err != nil {
+		return fmt.Errorf("delete change plan: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return tx.Commit()
+}
+
+// TicketExists reports whether the ticket exists.
+func (r *ChangeRepository) TicketExists(ctx context.Context, ticketID int) (bool, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx, database.ConvertPlaceholders(
+		"SELECT COUNT(*) FROM ticket WHERE id = ?",
+	), ticketID).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("check ticket %d: %w", ticketID, err)
+	}
+	return count > 0, nil
+}
+
+// ServiceExists reports whether a valid service with the ID exists.
+func (r *ChangeRepository) ServiceExists(ctx context.Context, serviceID int) (bool, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx, database.ConvertPlaceholders(
+		"SELECT COUNT(*) FROM service WHERE id = ? AND valid_id = 1",
+	), serviceID).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("check service %d: %w", serviceID, err)
+	}
+	return count > 0, nil
+}
+
+func scanChangePlan(row kbRowScanner) (*models.ChangePlan, error) {
+	var plan models.ChangePlan
+	var risk, approval string
+	if err := row.Scan(&plan.TicketID, &plan.TicketNumber, &plan.Title, &plan.QueueID, &plan.State,
+		&plan.PlannedStart, &plan.PlannedEnd, &risk, &plan.ImplementationPlan, &plan.BackoutPlan,
+		&approval, &plan.CreateTime, &plan.CreateBy, &plan.ChangeTime, &plan.ChangeBy); err != nil {
+		return nil, err
+	}
+	plan.Risk = models.ChangeRisk(risk)
+	plan.ApprovalStatus = models.ApprovalStatus(approval)
+	return &plan, nil
+}
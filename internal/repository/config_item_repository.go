@@ -0,0 +1,450 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/models"
+)
+
+const configItemClassSelect = `
+	SELECT id, name, COALESCE(description, ''), COALESCE(attributes, ''), valid_id,
+	       create_time, create_by, change_time, change_by
+	FROM cmdb_class`
+
+const configItemSelect = `
+	SELECT ci.id, ci.number, ci.class_id, cl.name, ci.name, ci.state,
+	       COALESCE(ci.customer_id, ''), COALESCE(ci.customer_user_id, ''), COALESCE(ci.attributes, ''),
+	       ci.create_time, ci.create_by, ci.change_time, ci.change_by
+	FROM cmdb_ci ci
+	JOIN cmdb_class cl ON cl.id = ci.class_id`
+
+// ConfigItemRepository handles database operations for the CMDB: classes
+// of configuration items, the items and their ticket links.
+type ConfigItemRepository struct {
+	db *sql.DB
+}
+
+// NewConfigItemRepository creates a new configuration item repository.
+func NewConfigItemRepository(db *sql.DB) *ConfigItemRepository {
+	return &ConfigItemRepository{db: db}
+}
+
+// ListClasses returns the classes ordered by name; validOnly skips invalid
+// classes.
+func (r *ConfigItemRepository) ListClasses(ctx context.Context, validOnly bool) ([]*models.ConfigItemClass, error) {
+	query := configItemClassSelect
+	if validOnly {
+		query += " WHERE valid_id = 1"
+	}
+	rows, err := r.db.QueryContext(ctx, database.ConvertPlaceholders(query+" ORDER BY name"))
+	if err != nil {
+		return nil, fmt.Errorf("query config item classes: %w", err)
+	}
+	defer rows.Close()
+
+	classes := []*models.ConfigItemClass{}
+	for rows.Next() {
+		class, err := scanConfigItemClass(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan config item class: %w", err)
+		}
+		classes = append(classes, class)
+	}
+	return classes, rows.Err()
+}
+
+// GetClass returns a class, or nil if it does not exist.
+func (r *ConfigItemRepository) GetClass(ctx context.Context, id int) (*models.ConfigItemClass, error) {
+	class, err := scanConfigItemClass(r.db.QueryRowContext(ctx, database.ConvertPlaceholders(configItemClassSelect+" WHERE id = ?"), id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query config item class: %w", err)
+	}
+	return class, nil
+}
+
+// ClassNameExists reports whether another class already uses the name.
+func (r *ConfigItemRepository) ClassNameExists(ctx context.Context, name string, excludeID int) (bool, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx, database.ConvertPlaceholders(
+		"SELECT COUNT(*) FROM cmdb_class WHERE name = ? AND id <> ?"), name, excludeID).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("check config item class name: %w", err)
+	}
+	return count > 0, nil
+}
+
+// CreateClass inserts a class, setting its ID.
+func (r *ConfigItemRepository) CreateClass(ctx context.Context, class *models.ConfigItemClass) error {
+	attributes, err := encodeConfigItemJSON(class.Attributes, len(class.Attributes) > 0)
+	if err != nil {
+		return err
+	}
+	id, err := database.GetAdapter().InsertWithReturning(r.db, database.ConvertPlaceholders(`
+		INSERT INTO cmdb_class (name, description, attributes, valid_id, create_time, create_by, change_time, change_by)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id`),
+		class.Name, class.Description, attributes, class.ValidID, class.CreateTime, class.CreateBy, class.ChangeTime, class.ChangeBy)
+	if err != nil {
+		return fmt.Errorf("insert config item class: %w", err)
+	}
+	class.ID = int(id)
+	return nil
+}
+
+// UpdateClass stores changes to a class. It returns sql.ErrNoRows when the
+// class does not exist.
+func (r *ConfigItemRepository) UpdateClass(ctx context.Context, class *models.ConfigItemClass) error {
+	attributes, err := encodeConfigItemJSON(class.Attributes, len(class.Attributes) > 0)
+	if err != nil {
+		return err
+	}
+	result, err := r.db.ExecContext(ctx, database.ConvertPlaceholders(`
+		UPDATE cmdb_class
+		SET name = ?, description = ?, attributes = ?, valid_id = ?, change_time = ?, change_by = ?
+		WHERE id = ?`),
+		class.Name, class.Description, attributes, class.ValidID, class.ChangeTime, class.ChangeBy, class.ID)
+	if err != nil {
+		return fmt.Errorf("update config item class: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// DeleteClass deletes a class, reporting whether it existed. Classes that
+// still have items cannot be deleted.
+func (r *ConfigItemRepository) DeleteClass(ctx context.Context, id int) (bool, error) {
+	result, err := r.db.ExecContext(ctx, database.ConvertPlaceholders("DELETE FROM cmdb_class WHERE id = ?"), id)
+	if err != nil {
+		return false, fmt.Errorf("delete config item class: %w", err)
+	}
+	n, _ := result.RowsAffected()
+	return n > 0, nil
+}
+
+// CountClassItems returns how many items belong to the class.
+func (r *ConfigItemRepository) CountClassItems(ctx context.Context, classID int) (int, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx, database.ConvertPlaceholders(
+		"SELECT COUNT(*) FROM cmdb_ci WHERE class_id = ?"), classID).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("count config items of class: %w", err)
+	}
+	return count, nil
+}
+
+// ListItems returns a page of the items matching the filter ordered by
+// name, and the number of matching items.
+func (r *ConfigItemRepository) ListItems(ctx context.Context, filter models.ConfigItemFilter) ([]*models.ConfigItem, int, error) {
+	var where []string
+	var args []interface{}
+	if filter.ClassID > 0 {
+		where = append(where, "ci.class_id = ?")
+		args = append(args, filter.ClassID)
+	}
+	if filter.State != "" {
+		where = append(where, "ci.state = ?")
+		args = append(args, filter.State)
+	}
+	if filter.CustomerID != "" {
+		where = append(where, "ci.customer_id = ?")
+		args = append(args, filter.CustomerID)
+	}
+	if filter.CustomerUserID != "" {
+		where = append(where, "ci.customer_user_id = ?")
+		args = append(args, filter.CustomerUserID)
+	}
+	if filter.TicketID > 0 {
+		where = append(where, "EXISTS (SELECT 1 FROM cmdb_ci_ticket l WHERE l.ci_id = ci.id AND l.ticket_id = ?)")
+		args = append(args, filter.TicketID)
+	}
+	if q := strings.TrimSpace(filter.Query); q != "" {
+		pattern := "%" + strings.ToLower(q) + "%"
+		where = append(where, "(LOWER(ci.number) LIKE ? OR LOWER(ci.name) LIKE ? OR LOWER(COALESCE(ci.attributes, '')) LIKE ?)")
+		args = append(args, pattern, pattern, pattern)
+	}
+	conditions := ""
+	if len(where) > 0 {
+		conditions = " WHERE " + strings.Join(where, " AND ")
+	}
+
+	var total int
+	if err := r.db.QueryRowContext(ctx, database.ConvertPlaceholders(
+		"SELECT COUNT(*) FROM cmdb_ci ci"+conditions), args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("count config items: %w", err)
+	}
+
+	query := configItemSelect + conditions + " ORDER BY ci.name, ci.id"
+	if filter.Limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d OFFSET %d", filter.Limit, filter.Offset)
+	}
+	rows, err := r.db.QueryContext(ctx, database.ConvertPlaceholders(query), args...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("query config items: %w", err)
+	}
+	defer rows.Close()
+
+	items := []*models.ConfigItem{}
+	for rows.Next() {
+		item, err := scanConfigItem(rows)
+		if err != nil {
+			return nil, 0, fmt.Errorf("scan config item: %w", err)
+		}
+		items = append(items, item)
+	}
+	return items, total, rows.Err()
+}
+
+// GetItem returns an item with its linked tickets, or nil if it does not
+// exist.
+func (r *ConfigItemRepository) GetItem(ctx context.Context, id int64) (*models.ConfigItem, error) {
+	item, err := scanConfigItem(r.db.QueryRowContext(ctx, database.ConvertPlaceholders(configItemSelect+" WHERE ci.id = ?"), id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query config item: %w", err)
+	}
+
+	rows, err := r.db.QueryContext(ctx, database.ConvertPlaceholders(`
+		SELECT t.id, t.tn, COALESCE(t.title, ''), l.create_time, l.create_by
+		FROM cmdb_ci_ticket l
+		JOIN ticket t ON t.id = l.ticket_id
+		WHERE l.ci_id = ?
+		ORDER BY l.create_time DESC, t.id DESC`), id)
+	if err != nil {
+		return nil, fmt.Errorf("query config item tickets: %w", err)
+	}
+	defer rows.Close()
+	item.Tickets = []models.ConfigItemTicket{}
+	for rows.Next() {
+		var t models.ConfigItemTicket
+		if err := rows.Scan(&t.ID, &t.Number, &t.Title, &t.LinkedTime, &t.LinkedBy); err != nil {
+			return nil, fmt.Errorf("scan config item ticket: %w", err)
+		}
+		item.Tickets = append(item.Tickets, t)
+	}
+	return item, rows.Err()
+}
+
+// NumberExists reports whether another item already uses the number.
+func (r *ConfigItemRepository) NumberExists(ctx context.Context, number string, excludeID int64) (bool, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx, database.ConvertPlaceholders(
+		"SELECT COUNT(*) FROM cmdb_ci WHERE number = ? AND id <> ?"), number, excludeID).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("check config item number: %w", err)
+	}
+	return count > 0, nil
+}
+
+// CreateItem inserts an item, setting its ID. Items without a number are
+// numbered CI-<id>, zero-padded to six digits.
+func (r *ConfigItemRepository) CreateItem(ctx context.Context, item *models.ConfigItem) error {
+	attributes, err := encodeConfigItemJSON(item.Attributes, len(item.Attributes) > 0)
+	if err != nil {
+		return err
+	}
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin config item insert: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck // No-op after commit
+
+	number := item.Number
+	if number == "" {
+		// Unique until replaced by the ID-based number below
+		number = fmt.Sprintf("new-%d", time.Now().UnixNano())
+	}
+	id, err := database.GetAdapter().InsertWithReturningTx(tx, database.ConvertPlaceholders(`
+		INSERT INTO cmdb_ci (number, class_id, name, state, customer_id, customer_user_id, attributes,
+			create_time, create_by, change_time, change_by)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id`),
+		number, item.ClassID, item.Name, item.State, nullString(item.CustomerID), nullString(item.CustomerUserID), attributes,
+		item.CreateTime, item.CreateBy, item.ChangeTime, item.ChangeBy)
+	if err != nil {
+		return fmt.Errorf("insert config item: %w", err)
+	}
+	if item.Number == "" {
+		number = fmt.Sprintf("CI-%06d", id)
+		if _, err := tx.ExecContext(ctx, database.ConvertPlaceholders(
+			"UPDATE cmdb_ci SET number = ? WHERE id = ?"), number, id); err != nil {
+			return fmt.Errorf("number config item: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit config item insert: %w", err)
+	}
+	item.ID, item.Number = id, number
+	return nil
+}
+
+// UpdateItem stores changes to an item. It returns sql.ErrNoRows when the
+// item does not exist.
+func (r *ConfigItemRepository) UpdateItem(ctx context.Context, item *models.ConfigItem) error {
+	attributes, err := encodeConfigItemJSON(item.Attributes, len(item.Attributes) > 0)
+	if err != nil {
+		return err
+	}
+	result, err := r.db.ExecContext(ctx, database.ConvertPlaceholders(`
+		UPDATE cmdb_ci
+		SET number = ?, class_id = ?, name = ?, state = ?, customer_id = ?, customer_user_id = ?, attributes = ?,
+		    change_time = ?, change_by = ?
+		WHERE id = ?`),
+		item.Number, item.ClassID, item.Name, item.State, nullString(item.CustomerID), nullString(item.CustomerUserID), attributes,
+		item.ChangeTime, item.ChangeBy, item.ID)
+	if err != nil {
+		return fmt.Errorf("update config item: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// DeleteItem deletes an item with its ticket links, reporting whether it
+// existed.
+func (r *ConfigItemRepository) DeleteItem(ctx context.Context, id int64) (bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("begin config item delete: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck // No-op after commit
+
+	if _, err := tx.ExecContext(ctx, database.ConvertPlaceholders("DELETE FROM cmdb_ci_ticket WHERE ci_id = ?"), id); err != nil {
+		return false, fmt.Errorf("delete config item ticket links: %w", err)
+	}
+	result, err := tx.ExecContext(ctx, database.ConvertPlaceholders("DELETE FROM cmdb_ci WHERE id = ?"), id)
+	if err != nil {
+		return false, fmt.Errorf("delete config item: %w", err)
+	}
+	n, _ := result.RowsAffected()
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("commit config item delete: %w", err)
+	}
+	return n > 0, nil
+}
+
+// LinkTicket links an item to a ticket, reporting whether the link is new.
+func (r *ConfigItemRepository) LinkTicket(ctx context.Context, itemID, ticketID int64, userID int, now time.Time) (bool, error) {
+	var count int
+	if err := r.db.QueryRowContext(ctx, database.ConvertPlaceholders(
+		"SELECT COUNT(*) FROM cmdb_ci_ticket WHERE ci_id = ? AND ticket_id = ?"), itemID, ticketID).Scan(&count); err != nil {
+		return false, fmt.Errorf("check config item ticket link: %w", err)
+	}
+	if count > 0 {
+		return false, nil
+	}
+	if _, err := r.db.ExecContext(ctx, database.ConvertPlaceholders(`
+		INSERT INTO cmdb_ci_ticket (ci_id, ticket_id, create_time, create_by)
+		VALUES (?, ?, ?, ?)`), itemID, ticketID, now, userID); err != nil {
+		return false, fmt.Errorf("insert config item ticket link: %w", err)
+	}
+	return true, nil
+}
+
+// UnlinkTicket removes the link between an item and a ticket, reporting
+// whether it existed.
+func (r *ConfigItemRepository) UnlinkTicket(ctx context.Context, itemID, ticketID int64) (bool, error) {
+	result, err := r.db.ExecContext(ctx, database.ConvertPlaceholders(
+		"DELETE FROM cmdb_ci_ticket WHERE ci_id = ? AND ticket_id = ?"), itemID, ticketID)
+	if err != nil {
+		return false, fmt.Errorf("delete config item ticket link: %w", err)
+	}
+	n, _ := result.RowsAffected()
+	return n > 0, nil
+}
+
+// TicketExists reports whether the ticket exists.
+func (r *ConfigItemRepository) TicketExists(ctx context.Context, ticketID int64) (bool, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx, database.ConvertPlaceholders(
+		"SELECT COUNT(*) FROM ticket WHERE id = ?"), ticketID).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("check ticket: %w", err)
+	}
+	return count > 0, nil
+}
+
+// CustomerCompanyExists reports whether the customer company exists.
+func (r *ConfigItemRepository) CustomerCompanyExists(ctx context.Context, customerID string) (bool, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx, database.ConvertPlaceholders(
+		"SELECT COUNT(*) FROM customer_company WHERE customer_id = ?"), customerID).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("check customer company: %w", err)
+	}
+	return count > 0, nil
+}
+
+// CustomerUserCompany returns the customer company of a customer user, and
+// whether the user exists.
+func (r *ConfigItemRepository) CustomerUserCompany(ctx context.Context, login string) (string, bool, error) {
+	var customerID string
+	err := r.db.QueryRowContext(ctx, database.ConvertPlaceholders(
+		"SELECT customer_id FROM customer_user WHERE login = ?"), login).Scan(&customerID)
+	if err == sql.ErrNoRows {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("query customer user: %w", err)
+	}
+	return customerID, true, nil
+}
+
+func scanConfigItemClass(row kbRowScanner) (*models.ConfigItemClass, error) {
+	var class models.ConfigItemClass
+	var attributes string
+	if err := row.Scan(&class.ID, &class.Name, &class.Description, &attributes, &class.ValidID,
+		&class.CreateTime, &class.CreateBy, &class.ChangeTime, &class.ChangeBy); err != nil {
+		return nil, err
+	}
+	class.Attributes = []models.ConfigItemAttribute{}
+	if attributes != "" {
+		if err := json.Unmarshal([]byte(attributes), &class.Attributes); err != nil {
+			return nil, fmt.Errorf("decode attributes of config item class %d: %w", class.ID, err)
+		}
+	}
+	return &class, nil
+}
+
+func scanConfigItem(row kbRowScanner) (*models.ConfigItem, error) {
+	var item models.ConfigItem
+	var attributes string
+	if err := row.Scan(&item.ID, &item.Number, &item.ClassID, &item.ClassName, &item.Name, &item.State,
+		&item.CustomerID, &item.CustomerUserID, &attributes,
+		&item.CreateTime, &item.CreateBy, &item.ChangeTime, &item.ChangeBy); err != nil {
+		return nil, err
+	}
+	item.Attributes = map[string]interface{}{}
+	if attributes != "" {
+		if err := json.Unmarshal([]byte(attributes), &item.Attributes); err != nil {
+			return nil, fmt.Errorf("decode attributes of config item %d: %w", item.ID, err)
+		}
+	}
+	return &item, nil
+}
+
+// encodeConfigItemJSON returns v as JSON for a TEXT column, or nil when
+// present is false.
+func encodeConfigItemJSON(v interface{}, present bool) (interface{}, error) {
+	if !present {
+		return nil, nil
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("encode config item attributes: %w", err)
+	}
+	return string(b), nil
+}
@@ -0,0 +1,158 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/testutil"
+)
+
+func TestConfigItemRepository(t *testing.T) {
+	db := testutil.UseMigratedDB(t)
+	_, err := db.Exec(`INSERT INTO users (id, login, pw, first_name, last_name, valid_id, create_time, create_by, change_time, change_by)
+		VALUES (1, 'root@localhost', 'x', 'Admin', 'OTRS', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`)
+	require.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO customer_company (customer_id, name, valid_id, create_time, create_by, change_time, change_by)
+		VALUES ('acme', 'Acme', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`)
+	require.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO customer_user (login, email, customer_id, first_name, last_name, valid_id,
+		create_time, create_by, change_time, change_by)
+		VALUES ('jane', 'jane@example.com', 'acme', 'Jane', 'Doe', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+	insertArchiveTestTicket(t, db, 1, 1, now)
+	repo := NewConfigItemRepository(db)
+
+	class := &models.ConfigItemClass{Name: "Laptop", ValidID: 1,
+		Attributes: []models.ConfigItemAttribute{{Key: "serial", Label: "Serial", Type: "text", Required: true}},
+		CreateTime: now, CreateBy: 1, ChangeTime: now, ChangeBy: 1}
+	require.NoError(t, repo.CreateClass(ctx, class))
+	require.NotZero(t, class.ID)
+	server := &models.ConfigItemClass{Name: "Server", ValidID: 2, CreateTime: now, CreateBy: 1, ChangeTime: now, ChangeBy: 1}
+	require.NoError(t, repo.CreateClass(ctx, server))
+
+	exists, err := repo.ClassNameExists(ctx, "Laptop", 0)
+	require.NoError(t, err)
+	assert.True(t, exists)
+	exists, err = repo.ClassNameExists(ctx, "Laptop", class.ID)
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	classes, err := repo.ListClasses(ctx, true)
+	require.NoError(t, err)
+	require.Len(t, classes, 1)
+	assert.Equal(t, class.Attributes, classes[0].Attributes)
+	loaded, err := repo.GetClass(ctx, server.ID)
+	require.NoError(t, err)
+	assert.Empty(t, loaded.Attributes)
+
+	laptop := &models.ConfigItem{ClassID: class.ID, Name: "Jane's laptop", State: models.ConfigItemStateInUse,
+		CustomerID: "acme", CustomerUserID: "jane", Attributes: map[string]interface{}{"serial": "SN-4711"},
+		CreateTime: now, CreateBy: 1, ChangeTime: now, ChangeBy: 1}
+	require.NoError(t, repo.CreateItem(ctx, laptop))
+	assert.Equal(t, "CI-000001", laptop.Number)
+	spare := &models.ConfigItem{Number: "ASSET-9", ClassID: class.ID, Name: "Spare laptop", State: models.ConfigItemStateInStock,
+		CreateTime: now, CreateBy: 1, ChangeTime: now, ChangeBy: 1}
+	require.NoError(t, repo.CreateItem(ctx, spare))
+	assert.Equal(t, "ASSET-9", spare.Number)
+
+	exists, err = repo.NumberExists(ctx, "ASSET-9", laptop.ID)
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	n, err := repo.CountClassItems(ctx, class.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	created, err := repo.LinkTicket(ctx, laptop.ID, 1, 1, now)
+	require.NoError(t, err)
+	assert.True(t, created)
+	created, err = repo.LinkTicket(ctx, laptop.ID, 1, 1, now)
+	require.NoError(t, err)
+	assert.False(t, created, "links are unique")
+
+	item, err := repo.GetItem(ctx, laptop.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Laptop", item.ClassName)
+	assert.Equal(t, "jane", item.CustomerUserID)
+	assert.Equal(t, "SN-4711", item.Attributes["serial"])
+	require.Len(t, item.Tickets, 1)
+	assert.Equal(t, int64(1), item.Tickets[0].ID)
+
+	for _, tc := range []struct {
+		filter models.ConfigItemFilter
+		want   []string
+	}{
+		{models.ConfigItemFilter{}, []string{"Jane's laptop", "Spare laptop"}},
+		{models.ConfigItemFilter{Query: "sn-47"}, []string{"Jane's laptop"}},
+		{models.ConfigItemFilter{Query: "asset"}, []string{"Spare laptop"}},
+		{models.ConfigItemFilter{State: models.ConfigItemStateInStock}, []string{"Spare laptop"}},
+		{models.ConfigItemFilter{CustomerID: "acme"}, []string{"Jane's laptop"}},
+		{models.ConfigItemFilter{CustomerUserID: "jane"}, []string{"Jane's laptop"}},
+		{models.ConfigItemFilter{TicketID: 1}, []string{"Jane's laptop"}},
+		{models.ConfigItemFilter{ClassID: server.ID}, []string{}},
+		{models.ConfigItemFilter{Limit: 1, Offset: 1}, []string{"Spare laptop"}},
+	} {
+		items, total, err := repo.ListItems(ctx, tc.filter)
+		require.NoError(t, err)
+		names := []string{}
+		for _, item := range items {
+			names = append(names, item.Name)
+		}
+		assert.Equal(t, tc.want, names, "%+v", tc.filter)
+		if tc.filter.Limit == 0 {
+			assert.Equal(t, len(tc.want), total)
+		} else {
+			assert.Equal(t, 2, total)
+		}
+	}
+
+	spare.State, spare.CustomerID = models.ConfigItemStateInUse, "acme"
+	spare.ChangeTime = now.Add(time.Hour)
+	require.NoError(t, repo.UpdateItem(ctx, spare))
+	item, err = repo.GetItem(ctx, spare.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.ConfigItemStateInUse, item.State)
+	assert.Equal(t, "acme", item.CustomerID)
+	assert.Empty(t, item.CustomerUserID)
+	assert.Empty(t, item.Tickets)
+
+	removed, err := repo.UnlinkTicket(ctx, laptop.ID, 1)
+	require.NoError(t, err)
+	assert.True(t, removed)
+	removed, err = repo.UnlinkTicket(ctx, laptop.ID, 1)
+	require.NoError(t, err)
+	assert.False(t, removed)
+
+	_, err = repo.LinkTicket(ctx, laptop.ID, 1, 1, now)
+	require.NoError(t, err)
+	deleted, err := repo.DeleteItem(ctx, laptop.ID)
+	require.NoError(t, err)
+	assert.True(t, deleted)
+	assert.Zero(t, countRows(t, db, "SELECT COUNT(*) FROM cmdb_ci_ticket"))
+	item, err = repo.GetItem(ctx, laptop.ID)
+	require.NoError(t, err)
+	assert.Nil(t, item)
+
+	ok, err := repo.TicketExists(ctx, 1)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = repo.CustomerCompanyExists(ctx, "globex")
+	require.NoError(t, err)
+	assert.False(t, ok)
+	company, found, err := repo.CustomerUserCompany(ctx, "jane")
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "acme", company)
+
+	deleted, err = repo.DeleteClass(ctx, server.ID)
+	require.NoError(t, err)
+	assert.True(t, deleted)
+}
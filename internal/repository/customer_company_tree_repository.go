@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/models"
+)
+
+// CustomerCompanyTreeRepository reads and writes the parent links of the
+// customer company hierarchy.
+type CustomerCompanyTreeRepository struct {
+	db *sql.DB
+}
+
+// NewCustomerCompanyTreeRepository creates a new customer company tree
+// repository.
+func NewCustomerCompanyTreeRepository(db *sql.DB) *CustomerCompanyTreeRepository {
+	return &CustomerCompanyTreeRepository{db: db}
+}
+
+// ListNodes returns every customer company with its parent, ordered by
+// name, without children.
+func (r *CustomerCompanyTreeRepository) ListNodes(ctx context.Context) ([]*models.CustomerCompanyNode, error) {
+	rows, err := r.db.QueryContext(ctx, database.ConvertPlaceholders(`
+		SELECT customer_id, name, COALESCE(parent_customer_id, ''), valid_id
+		FROM customer_company
+		ORDER BY name`))
+	if err != nil {
+		return nil, fmt.Errorf("query customer companies: %w", err)
+	}
+	defer rows.Close()
+
+	nodes, err := database.CollectRows(rows, func(rows *sql.Rows) (*models.CustomerCompanyNode, error) {
+		var n models.CustomerCompanyNode
+		return &n, rows.Scan(&n.CustomerID, &n.Name, &n.ParentCustomerID, &n.ValidID)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("scan customer company: %w", err)
+	}
+	return nodes, nil
+}
+
+// SetParent moves customerID below parentID, or to the top level when
+// parentID is empty. It reports false when the company does not exist.
+func (r *CustomerCompanyTreeRepository) SetParent(ctx context.Context, customerID, parentID string, userID int) (bool, error) {
+	var parent interface{}
+	if parentID != "" {
+		parent = parentID
+	}
+	res, err := r.db.ExecContext(ctx, database.ConvertPlaceholders(`
+		UPDATE customer_company
+		SET parent_customer_id = ?, change_time = ?, change_by = ?
+		WHERE customer_id = ?`), parent, time.Now(), userID, customerID)
+	if err != nil {
+		return false, fmt.Errorf("update customer company parent: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
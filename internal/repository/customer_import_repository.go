@@ -0,0 +1,181 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/models"
+)
+
+// CustomerImportRecord is a customer user or company to be written by an
+// import, by column name.
+type CustomerImportRecord struct {
+	Create  bool
+	Columns map[string]interface{}
+}
+
+// customerImportTable describes the table an import kind writes to.
+type customerImportTable struct {
+	name string
+	key  string
+	// columns lists the columns an import may write, other than the key.
+	columns []string
+	// defaults fills NOT NULL columns missing from a new record.
+	defaults map[string]interface{}
+}
+
+var customerImportTables = map[models.CustomerImportKind]customerImportTable{
+	models.CustomerImportUsers: {
+		name: "customer_user",
+		key:  "login",
+		columns: []string{"email", "customer_id", "pw", "title", "first_name", "last_name",
+			"phone", "fax", "mobile", "street", "zip", "city", "country", "comments", "valid_id"},
+		defaults: map[string]interface{}{"email": "", "customer_id": "", "first_name": "", "last_name": "", "valid_id": 1},
+	},
+	models.CustomerImportCompanies: {
+		name:     "customer_company",
+		key:      "customer_id",
+		columns:  []string{"name", "street", "zip", "city", "country", "url", "comments", "valid_id"},
+		defaults: map[string]interface{}{"name": "", "valid_id": 1},
+	},
+}
+
+// CustomerImportRepository reads the existing customer users and companies
+// an import is checked against and writes imported records.
+type CustomerImportRepository struct {
+	db *sql.DB
+}
+
+// NewCustomerImportRepository creates a new customer import repository.
+func NewCustomerImportRepository(db *sql.DB) *CustomerImportRepository {
+	return &CustomerImportRepository{db: db}
+}
+
+// CustomerUserLogins returns the logins of all customer users.
+func (r *CustomerImportRepository) CustomerUserLogins(ctx context.Context) (map[string]bool, error) {
+	rows, err := r.db.QueryContext(ctx, database.ConvertPlaceholders(`SELECT login FROM customer_user`))
+	if err != nil {
+		return nil, fmt.Errorf("query customer user logins: %w", err)
+	}
+	defer rows.Close()
+
+	logins := make(map[string]bool)
+	for rows.Next() {
+		var login string
+		if err := rows.Scan(&login); err != nil {
+			return nil, fmt.Errorf("scan customer user login: %w", err)
+		}
+		logins[login] = true
+	}
+	return logins, rows.Err()
+}
+
+// CustomerCompanyNames returns the names of all customer companies by
+// customer ID.
+func (r *CustomerImportRepository) CustomerCompanyNames(ctx context.Context) (map[string]string, error) {
+	rows, err := r.db.QueryContext(ctx, database.ConvertPlaceholders(`SELECT customer_id, name FROM customer_company`))
+	if err != nil {
+		return nil, fmt.Errorf("query customer companies: %w", err)
+	}
+	defer rows.Close()
+
+	names := make(map[string]string)
+	for rows.Next() {
+		var id, name string
+		if err := rows.Scan(&id, &name); err != nil {
+			return nil, fmt.Errorf("scan customer company: %w", err)
+		}
+		names[id] = name
+	}
+	return names, rows.Err()
+}
+
+// Apply inserts or updates records in one transaction: either all of them
+// are written or none. Columns an import may not write are ignored.
+func (r *CustomerImportRepository) Apply(ctx context.Context, kind models.CustomerImportKind, records []CustomerImportRecord, userID int) error {
+	table, ok := customerImportTables[kind]
+	if !ok {
+		return fmt.Errorf("unknown customer import kind %q", kind)
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin customer import: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	now := time.Now()
+	for _, rec := range records {
+		key := rec.Columns[table.key]
+		if rec.Create {
+			err = insertCustomerImportRecord(ctx, tx, table, rec, now, userID)
+		} else {
+			err = updateCustomerImportRecord(ctx, tx, table, rec, now, userID)
+		}
+		if err != nil {
+			return fmt.Errorf("import %s %v: %w", table.name, key, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// importColumns returns the columns of rec that an import may write, sorted.
+func (t customerImportTable) importColumns(rec CustomerImportRecord) []string {
+	var cols []string
+	for _, col := range t.columns {
+		if _, ok := rec.Columns[col]; ok {
+			cols = append(cols, col)
+		}
+	}
+	sort.Strings(cols)
+	return cols
+}
+
+func insertCustomerImportRecord(ctx context.Context, tx *sql.Tx, t customerImportTable, rec CustomerImportRecord, now time.Time, userID int) error {
+	cols := []string{t.key}
+	args := []interface{}{rec.Columns[t.key]}
+	for _, col := range t.importColumns(rec) {
+		cols = append(cols, col)
+		args = append(args, rec.Columns[col])
+	}
+	var missing []string
+	for col := range t.defaults {
+		if _, ok := rec.Columns[col]; !ok {
+			missing = append(missing, col)
+		}
+	}
+	sort.Strings(missing)
+	for _, col := range missing {
+		cols = append(cols, col)
+		args = append(args, t.defaults[col])
+	}
+	cols = append(cols, "create_time", "create_by", "change_time", "change_by")
+	args = append(args, now, userID, now, userID)
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(cols)), ", ")
+	//nolint:gosec // G201 - table and column names come from customerImportTables
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", t.name, strings.Join(cols, ", "), placeholders)
+	_, err := tx.ExecContext(ctx, database.ConvertPlaceholders(query), args...)
+	return err
+}
+
+func updateCustomerImportRecord(ctx context.Context, tx *sql.Tx, t customerImportTable, rec CustomerImportRecord, now time.Time, userID int) error {
+	var sets []string
+	var args []interface{}
+	for _, col := range t.importColumns(rec) {
+		sets = append(sets, col+" = ?")
+		args = append(args, rec.Columns[col])
+	}
+	sets = append(sets, "change_time = ?", "change_by = ?")
+	args = append(args, now, userID, rec.Columns[t.key])
+
+	//nolint:gosec // G201 - table and column names come from customerImportTables
+	query := fmt.Sprintf("UPDATE %s SET %s WHERE %s = ?", t.name, strings.Join(sets, ", "), t.key)
+	_, err := tx.ExecContext(ctx, database.ConvertPlaceholders(query), args...)
+	return err
+}
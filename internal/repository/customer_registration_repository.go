@@ -0,0 +1,248 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/models"
+)
+
+// ErrCustomerRegistrationDecided is returned when a registration changed
+// state in the meantime, e.g. it was approved twice.
+var ErrCustomerRegistrationDecided = errors.New("customer registration was already decided")
+
+const customerRegistrationSelect = `
+	SELECT id, email, first_name, last_name, customer_id, pw, status, COALESCE(remote_ip, ''),
+	       expires_at, verified_at, decided_at, decided_by, create_time, change_time
+	FROM customer_registration`
+
+// CustomerRegistrationRepository stores customer portal self-registrations
+// and creates their customer_user accounts.
+type CustomerRegistrationRepository struct {
+	db *sql.DB
+}
+
+// NewCustomerRegistrationRepository creates a new customer registration
+// repository.
+func NewCustomerRegistrationRepository(db *sql.DB) *CustomerRegistrationRepository {
+	return &CustomerRegistrationRepository{db: db}
+}
+
+// Create stores a new registration with the hash of its verification token.
+func (r *CustomerRegistrationRepository) Create(ctx context.Context, reg *models.CustomerRegistration, tokenHash string) error {
+	id, err := database.GetAdapter().InsertWithReturning(r.db, database.ConvertPlaceholders(`
+		INSERT INTO customer_registration (email, first_name, last_name, customer_id, pw, token_hash,
+			status, remote_ip, expires_at, create_time, change_time)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id`),
+		reg.Email, reg.FirstName, reg.LastName, reg.CustomerID, reg.Password, tokenHash,
+		reg.Status, reg.RemoteIP, reg.ExpiresAt, reg.CreateTime, reg.ChangeTime)
+	if err != nil {
+		return fmt.Errorf("insert customer registration: %w", err)
+	}
+	reg.ID = id
+	return nil
+}
+
+// Get returns a registration by ID, or nil if there is none.
+func (r *CustomerRegistrationRepository) Get(ctx context.Context, id int64) (*models.CustomerRegistration, error) {
+	return r.queryOne(ctx, customerRegistrationSelect+" WHERE id = ?", id)
+}
+
+// GetByTokenHash returns the registration with the verification token
+// hash, or nil if there is none.
+func (r *CustomerRegistrationRepository) GetByTokenHash(ctx context.Context, tokenHash string) (*models.CustomerRegistration, error) {
+	return r.queryOne(ctx, customerRegistrationSelect+" WHERE token_hash = ?", tokenHash)
+}
+
+func (r *CustomerRegistrationRepository) queryOne(ctx context.Context, query string, args ...interface{}) (*models.CustomerRegistration, error) {
+	reg, err := scanCustomerRegistration(r.db.QueryRowContext(ctx, database.ConvertPlaceholders(query), args...))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query customer registration: %w", err)
+	}
+	return reg, nil
+}
+
+// List returns registrations, newest first, optionally only those in one
+// state.
+func (r *CustomerRegistrationRepository) List(ctx context.Context, status string, limit, offset int) ([]*models.CustomerRegistration, error) {
+	query := customerRegistrationSelect
+	args := []interface{}{}
+	if status != "" {
+		query += " WHERE status = ?"
+		args = append(args, status)
+	}
+	query += " ORDER BY create_time DESC, id DESC LIMIT ? OFFSET ?"
+	args = append(args, limit, offset)
+
+	rows, err := r.db.QueryContext(ctx, database.ConvertPlaceholders(query), args...)
+	if err != nil {
+		return nil, fmt.Errorf("query customer registrations: %w", err)
+	}
+	defer rows.Close()
+
+	list := []*models.CustomerRegistration{}
+	for rows.Next() {
+		reg, err := scanCustomerRegistration(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan customer registration: %w", err)
+		}
+		list = append(list, reg)
+	}
+	return list, rows.Err()
+}
+
+// ExistsForEmail tells whether the address has a registration in the given
+// state.
+func (r *CustomerRegistrationRepository) ExistsForEmail(ctx context.Context, email, status string) (bool, error) {
+	var n int
+	err := r.db.QueryRowContext(ctx, database.ConvertPlaceholders(
+		"SELECT COUNT(*) FROM customer_registration WHERE email = ? AND status = ?"), email, status).Scan(&n)
+	if err != nil {
+		return false, fmt.Errorf("query customer registration: %w", err)
+	}
+	return n > 0, nil
+}
+
+// CustomerUserExists tells whether a customer user has the address as login
+// or email.
+func (r *CustomerRegistrationRepository) CustomerUserExists(ctx context.Context, email string) (bool, error) {
+	var n int
+	err := r.db.QueryRowContext(ctx, database.ConvertPlaceholders(
+		"SELECT COUNT(*) FROM customer_user WHERE LOWER(login) = ? OR LOWER(email) = ?"), email, email).Scan(&n)
+	if err != nil {
+		return false, fmt.Errorf("query customer user: %w", err)
+	}
+	return n > 0, nil
+}
+
+// DeleteUnverified removes the unverified registrations of an address, and
+// those of any address whose link expired before now.
+func (r *CustomerRegistrationRepository) DeleteUnverified(ctx context.Context, email string, now time.Time) error {
+	_, err := r.db.ExecContext(ctx, database.ConvertPlaceholders(`
+		DELETE FROM customer_registration
+		WHERE status = ? AND (email = ? OR expires_at < ?)`),
+		models.CustomerRegistrationUnverified, email, now)
+	if err != nil {
+		return fmt.Errorf("delete unverified customer registrations: %w", err)
+	}
+	return nil
+}
+
+// Delete removes a registration.
+func (r *CustomerRegistrationRepository) Delete(ctx context.Context, id int64) error {
+	if _, err := r.db.ExecContext(ctx, database.ConvertPlaceholders(
+		"DELETE FROM customer_registration WHERE id = ?"), id); err != nil {
+		return fmt.Errorf("delete customer registration: %w", err)
+	}
+	return nil
+}
+
+// MarkVerified moves an unverified registration to pending approval and
+// invalidates its link.
+func (r *CustomerRegistrationRepository) MarkVerified(ctx context.Context, id int64, now time.Time) error {
+	res, err := r.db.ExecContext(ctx, database.ConvertPlaceholders(`
+		UPDATE customer_registration
+		SET status = ?, token_hash = NULL, verified_at = ?, change_time = ?
+		WHERE id = ? AND status = ?`),
+		models.CustomerRegistrationPending, now, now, id, models.CustomerRegistrationUnverified)
+	if err != nil {
+		return fmt.Errorf("verify customer registration: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrCustomerRegistrationDecided
+	}
+	return nil
+}
+
+// Activate creates the customer_user account of a registration in state
+// from and marks the registration approved, in one transaction. decidedBy
+// is the approving admin, or nil when the account was created on
+// verification.
+func (r *CustomerRegistrationRepository) Activate(ctx context.Context, reg *models.CustomerRegistration, from string, decidedBy *int, now time.Time) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin customer registration: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var decidedAt *time.Time
+	if decidedBy != nil {
+		decidedAt = &now
+	}
+	res, err := tx.ExecContext(ctx, database.ConvertPlaceholders(`
+		UPDATE customer_registration
+		SET status = ?, token_hash = NULL, verified_at = COALESCE(verified_at, ?),
+			decided_at = ?, decided_by = ?, change_time = ?
+		WHERE id = ? AND status = ?`),
+		models.CustomerRegistrationApproved, now, decidedAt, decidedBy, now, reg.ID, from)
+	if err != nil {
+		return fmt.Errorf("approve customer registration: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrCustomerRegistrationDecided
+	}
+
+	createBy := 1
+	if decidedBy != nil {
+		createBy = *decidedBy
+	}
+	if _, err := tx.ExecContext(ctx, database.ConvertPlaceholders(`
+		INSERT INTO customer_user (login, email, customer_id, pw, first_name, last_name, valid_id,
+			create_time, create_by, change_time, change_by)
+		VALUES (?, ?, ?, ?, ?, ?, 1, ?, ?, ?, ?)`),
+		reg.Email, reg.Email, reg.CustomerID, reg.Password, reg.FirstName, reg.LastName,
+		now, createBy, now, createBy); err != nil {
+		return fmt.Errorf("create customer user: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit customer registration: %w", err)
+	}
+	reg.Status = models.CustomerRegistrationApproved
+	return nil
+}
+
+// Reject marks an unverified or pending registration rejected.
+func (r *CustomerRegistrationRepository) Reject(ctx context.Context, id int64, userID int, now time.Time) error {
+	res, err := r.db.ExecContext(ctx, database.ConvertPlaceholders(`
+		UPDATE customer_registration
+		SET status = ?, token_hash = NULL, decided_at = ?, decided_by = ?, change_time = ?
+		WHERE id = ? AND status IN (?, ?)`),
+		models.CustomerRegistrationRejected, now, userID, now, id,
+		models.CustomerRegistrationUnverified, models.CustomerRegistrationPending)
+	if err != nil {
+		return fmt.Errorf("reject customer registration: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrCustomerRegistrationDecided
+	}
+	return nil
+}
+
+func scanCustomerRegistration(row kbRowScanner) (*models.CustomerRegistration, error) {
+	var (
+		reg                   models.CustomerRegistration
+		verifiedAt, decidedAt sql.NullTime
+		decidedBy             sql.NullInt64
+	)
+	if err := row.Scan(&reg.ID, &reg.Email, &reg.FirstName, &reg.LastName, &reg.CustomerID, &reg.Password,
+		&reg.Status, &reg.RemoteIP, &reg.ExpiresAt, &verifiedAt, &decidedAt, &decidedBy,
+		&reg.CreateTime, &reg.ChangeTime); err != nil {
+		return nil, err
+	}
+	if verifiedAt.Valid {
+		reg.VerifiedAt = &verifiedAt.Time
+	}
+	if decidedAt.Valid {
+		reg.DecidedAt = &decidedAt.Time
+	}
+	reg.DecidedBy = intPtrFromNull(decidedBy)
+	return &reg, nil
+}
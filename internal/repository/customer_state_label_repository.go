@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/models"
+)
+
+// CustomerStateLabelRepository handles database operations for the
+// customer-facing labels of ticket states.
+type CustomerStateLabelRepository struct {
+	db *sql.DB
+}
+
+// NewCustomerStateLabelRepository creates a new customer state label
+// repository.
+func NewCustomerStateLabelRepository(db *sql.DB) *CustomerStateLabelRepository {
+	return &CustomerStateLabelRepository{db: db}
+}
+
+// ListStates returns every ticket state with the name of its state type
+// and its configured label, if any; StateName and StateType carry the
+// internal names, Custom marks states with a label.
+func (r *CustomerStateLabelRepository) ListStates(ctx context.Context) ([]*models.CustomerStateMapping, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT ts.id, ts.name, COALESCE(tst.name, ''), csl.label, csl.i18n_key
+		FROM ticket_state ts
+		LEFT JOIN ticket_state_type tst ON ts.type_id = tst.id
+		LEFT JOIN customer_state_label csl ON csl.state_id = ts.id
+		ORDER BY ts.id`)
+	if err != nil {
+		return nil, fmt.Errorf("query customer state labels: %w", err)
+	}
+	defer rows.Close()
+
+	states := []*models.CustomerStateMapping{}
+	for rows.Next() {
+		var state models.CustomerStateMapping
+		var label, key sql.NullString
+		if err := rows.Scan(&state.StateID, &state.StateName, &state.StateType, &label, &key); err != nil {
+			return nil, fmt.Errorf("scan customer state label: %w", err)
+		}
+		state.Custom = label.Valid
+		state.Label, state.I18nKey = label.String, key.String
+		states = append(states, &state)
+	}
+	return states, rows.Err()
+}
+
+// GetLabel returns the label of a state, or nil if none is configured.
+func (r *CustomerStateLabelRepository) GetLabel(ctx context.Context, stateID int) (*models.CustomerStateLabel, error) {
+	var label models.CustomerStateLabel
+	var key sql.NullString
+	err := r.db.QueryRowContext(ctx, database.ConvertPlaceholders(`
+		SELECT state_id, label, i18n_key, create_time, create_by, change_time, change_by
+		FROM customer_state_label WHERE state_id = ?`), stateID).Scan(
+		&label.StateID, &label.Label, &key, &label.CreateTime, &label.CreateBy, &label.ChangeTime, &label.ChangeBy)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query customer state label: %w", err)
+	}
+	label.I18nKey = key.String
+	return &label, nil
+}
+
+// StateExists reports whether the ticket state exists.
+func (r *CustomerStateLabelRepository) StateExists(ctx context.Context, stateID int) (bool, error) {
+	var count int
+	if err := r.db.QueryRowContext(ctx, database.ConvertPlaceholders(
+		"SELECT COUNT(*) FROM ticket_state WHERE id = ?"), stateID).Scan(&count); err != nil {
+		return false, fmt.Errorf("check ticket state: %w", err)
+	}
+	return count > 0, nil
+}
+
+// SetLabel stores the label of a state, replacing an existing one.
+func (r *CustomerStateLabelRepository) SetLabel(ctx context.Context, label *models.CustomerStateLabel, userID int, now time.Time) error {
+	key := sql.NullString{String: label.I18nKey, Valid: label.I18nKey != ""}
+	var count int
+	if err := r.db.QueryRowContext(ctx, database.ConvertPlaceholders(
+		"SELECT COUNT(*) FROM customer_state_label WHERE state_id = ?"), label.StateID).Scan(&count); err != nil {
+		return fmt.Errorf("check customer state label: %w", err)
+	}
+	if count > 0 {
+		if _, err := r.db.ExecContext(ctx, database.ConvertPlaceholders(`
+			UPDATE customer_state_label
+			SET label = ?, i18n_key = ?, change_time = ?, change_by = ?
+			WHERE state_id = ?`), label.Label, key, now, userID, label.StateID); err != nil {
+			return fmt.Errorf("update customer state label: %w", err)
+		}
+		return nil
+	}
+	if _, err := r.db.ExecContext(ctx, database.ConvertPlaceholders(`
+		INSERT INTO customer_state_label (state_id, label, i18n_key, create_time, create_by, change_time, change_by)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`), label.StateID, label.Label, key, now, userID, now, userID); err != nil {
+		return fmt.Errorf("insert customer state label: %w", err)
+	}
+	return nil
+}
+
+// DeleteLabel removes the label of a state.
+func (r *CustomerStateLabelRepository) DeleteLabel(ctx context.Context, stateID int) (bool, error) {
+	result, err := r.db.ExecContext(ctx, database.ConvertPlaceholders(
+		"DELETE FROM customer_state_label WHERE state_id = ?"), stateID)
+	if err != nil {
+		return false, fmt.Errorf("delete customer state label: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/testutil"
+)
+
+func TestCustomerStateLabelRepository(t *testing.T) {
+	db := testutil.UseMigratedDB(t)
+	_, err := db.Exec(`INSERT INTO users (id, login, pw, first_name, last_name, valid_id, create_time, create_by, change_time, change_by)
+		VALUES (1, 'root@localhost', 'x', 'Admin', 'OTRS', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+	repo := NewCustomerStateLabelRepository(db)
+
+	states, err := repo.ListStates(ctx)
+	require.NoError(t, err)
+	require.NotEmpty(t, states)
+	stateID := states[0].StateID
+	assert.NotEmpty(t, states[0].StateName)
+	assert.NotEmpty(t, states[0].StateType)
+	for _, state := range states {
+		assert.False(t, state.Custom)
+	}
+
+	exists, err := repo.StateExists(ctx, stateID)
+	require.NoError(t, err)
+	assert.True(t, exists)
+	exists, err = repo.StateExists(ctx, 9999)
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	label := &models.CustomerStateLabel{StateID: stateID, Label: "Received", CreateTime: now, CreateBy: 1, ChangeTime: now, ChangeBy: 1}
+	require.NoError(t, repo.SetLabel(ctx, label, 1, now))
+	loaded, err := repo.GetLabel(ctx, stateID)
+	require.NoError(t, err)
+	require.NotNil(t, loaded)
+	assert.Equal(t, "Received", loaded.Label)
+	assert.Empty(t, loaded.I18nKey)
+
+	label.Label, label.I18nKey = "Waiting for you", "status.waiting"
+	require.NoError(t, repo.SetLabel(ctx, label, 1, now.Add(time.Minute)))
+	loaded, err = repo.GetLabel(ctx, stateID)
+	require.NoError(t, err)
+	assert.Equal(t, "Waiting for you", loaded.Label)
+	assert.Equal(t, "status.waiting", loaded.I18nKey)
+
+	states, err = repo.ListStates(ctx)
+	require.NoError(t, err)
+	assert.True(t, states[0].Custom)
+	assert.Equal(t, "Waiting for you", states[0].Label)
+	assert.Equal(t, "status.waiting", states[0].I18nKey)
+
+	deleted, err := repo.DeleteLabel(ctx, stateID)
+	require.NoError(t, err)
+	assert.True(t, deleted)
+	deleted, err = repo.DeleteLabel(ctx, stateID)
+	require.NoError(t, err)
+	assert.False(t, deleted)
+	missing, err := repo.GetLabel(ctx, stateID)
+	require.NoError(t, err)
+	assert.Nil(t, missing)
+}
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
 	"time"
 
@@ -9,6 +10,9 @@ import (
 	"github.com/goatkit/goatflow/internal/models"
 )
 
+// ErrRoleNotFound is returned when a role does not exist.
+var ErrRoleNotFound = errors.New("role not found")
+
 // DBRoleRepository handles database operations for roles.
 type DBRoleRepository struct {
 	db *sql.DB
@@ -83,7 +87,7 @@ func (r *DBRoleRepository) GetByID(id int) (*models.DBRole, error) {
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("role not found")
+			return nil, ErrRoleNotFound
 		}
 		return nil, fmt.Errorf("failed to get role: %w", err)
 	}
@@ -113,7 +117,7 @@ func (r *DBRoleRepository) GetByName(name string) (*models.DBRole, error) {
 	)
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("role not found")
+			return nil, ErrRoleNotFound
 		}
 		return nil, fmt.Errorf("failed to get role: %w", err)
 	}
@@ -141,11 +145,11 @@ func (r *DBRoleRepository) Create(role *models.DBRole) error {
 		}
 		role.ID = int(id)
 	} else {
-		err := r.db.QueryRow(`
+		err := r.db.QueryRow(database.ConvertPlaceholders(`
 			INSERT INTO roles (name, comments, valid_id, create_time, create_by, change_time, change_by)
 			VALUES (?, ?, ?, ?, ?, ?, ?)
 			RETURNING id
-		`, role.Name, nullString(role.Comments), role.ValidID, role.CreateTime, role.CreateBy, role.ChangeTime, role.ChangeBy).Scan(&role.ID)
+		`), role.Name, nullString(role.Comments), role.ValidID, role.CreateTime, role.CreateBy, role.ChangeTime, role.ChangeBy).Scan(&role.ID)
 		if err != nil {
 			return fmt.Errorf("failed to create role: %w", err)
 		}
@@ -174,7 +178,7 @@ func (r *DBRoleRepository) Update(role *models.DBRole) error {
 		return fmt.Errorf("failed to get rows affected: %w", err)
 	}
 	if rowsAffected == 0 {
-		return fmt.Errorf("role not found")
+		return ErrRoleNotFound
 	}
 
 	return nil
@@ -194,7 +198,7 @@ func (r *DBRoleRepository) Delete(id int) error {
 		return fmt.Errorf("failed to get rows affected: %w", err)
 	}
 	if rowsAffected == 0 {
-		return fmt.Errorf("role not found")
+		return ErrRoleNotFound
 	}
 
 	return nil
@@ -314,11 +318,11 @@ func (r *DBRoleRepository) AddUserToRole(userID, roleID, createdBy int) error {
 			return fmt.Errorf("failed to add user to role: %w", err)
 		}
 	} else {
-		_, err := r.db.Exec(`
+		_, err := r.db.Exec(database.ConvertPlaceholders(`
 			INSERT INTO role_user (user_id, role_id, create_time, create_by, change_time, change_by)
 			VALUES (?, ?, ?, ?, ?, ?)
 			ON CONFLICT (user_id, role_id) DO UPDATE SET change_time = EXCLUDED.change_time, change_by = EXCLUDED.change_by
-		`, userID, roleID, now, createdBy, now, createdBy)
+		`), userID, roleID, now, createdBy, now, createdBy)
 		if err != nil {
 			return fmt.Errorf("failed to add user to role: %w", err)
 		}
@@ -354,19 +358,13 @@ func (r *DBRoleRepository) SetRoleUsers(roleID int, userIDs []int, changedBy int
 	}
 
 	// Insert new users
+	insertQuery := database.ConvertPlaceholders(`
+		INSERT INTO role_user (user_id, role_id, create_time, create_by, change_time, change_by)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`)
 	now := time.Now()
 	for _, userID := range userIDs {
-		if database.IsMySQL() {
-			_, err = tx.Exec(`
-				INSERT INTO role_user (user_id, role_id, create_time, create_by, change_time, change_by)
-				VALUES (?, ?, ?, ?, ?, ?)
-			`, userID, roleID, now, changedBy, now, changedBy)
-		} else {
-			_, err = tx.Exec(`
-				INSERT INTO role_user (user_id, role_id, create_time, create_by, change_time, change_by)
-				VALUES (?, ?, ?, ?, ?, ?)
-			`, userID, roleID, now, changedBy, now, changedBy)
-		}
+		_, err = tx.Exec(insertQuery, userID, roleID, now, changedBy, now, changedBy)
 		if err != nil {
 			return fmt.Errorf("failed to add user to role: %w", err)
 		}
@@ -391,19 +389,13 @@ func (r *DBRoleRepository) SetUserRoles(userID int, roleIDs []int, changedBy int
 	}
 
 	// Insert new roles
+	insertQuery := database.ConvertPlaceholders(`
+		INSERT INTO role_user (user_id, role_id, create_time, create_by, change_time, change_by)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`)
 	now := time.Now()
 	for _, roleID := range roleIDs {
-		if database.IsMySQL() {
-			_, err = tx.Exec(`
-				INSERT INTO role_user (user_id, role_id, create_time, create_by, change_time, change_by)
-				VALUES (?, ?, ?, ?, ?, ?)
-			`, userID, roleID, now, changedBy, now, changedBy)
-		} else {
-			_, err = tx.Exec(`
-				INSERT INTO role_user (user_id, role_id, create_time, create_by, change_time, change_by)
-				VALUES (?, ?, ?, ?, ?, ?)
-			`, userID, roleID, now, changedBy, now, changedBy)
-		}
+		_, err = tx.Exec(insertQuery, userID, roleID, now, changedBy, now, changedBy)
 		if err != nil {
 			return fmt.Errorf("failed to add role to user: %w", err)
 		}
@@ -412,6 +404,127 @@ func (r *DBRoleRepository) SetUserRoles(userID int, roleIDs []int, changedBy int
 	return tx.Commit()
 }
 
+// ListRolePermissions returns the group permissions a role grants, one
+// entry per group, ordered by group name.
+func (r *DBRoleRepository) ListRolePermissions(roleID int) ([]*models.RoleGroupPermissions, error) {
+	query := database.ConvertPlaceholders(`
+		SELECT gr.group_id, g.name, gr.permission_key
+		FROM group_role gr
+		INNER JOIN groups g ON g.id = gr.group_id
+		WHERE gr.role_id = ? AND gr.permission_value = 1
+		ORDER BY g.name, gr.permission_key
+	`)
+
+	rows, err := r.db.Query(query, roleID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list role permissions: %w", err)
+	}
+	defer rows.Close()
+
+	perms := []*models.RoleGroupPermissions{}
+	var current *models.RoleGroupPermissions
+	for rows.Next() {
+		var groupID int
+		var groupName, key string
+		if err := rows.Scan(&groupID, &groupName, &key); err != nil {
+			return nil, fmt.Errorf("failed to scan role permission: %w", err)
+		}
+		if current == nil || current.GroupID != groupID {
+			current = &models.RoleGroupPermissions{GroupID: groupID, GroupName: groupName}
+			perms = append(perms, current)
+		}
+		current.Permissions = append(current.Permissions, key)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read role permissions: %w", err)
+	}
+
+	return perms, nil
+}
+
+// SetRolePermissions sets the group permissions of a role (replaces all
+// existing).
+func (r *DBRoleRepository) SetRolePermissions(roleID int, perms []*models.RoleGroupPermissions, changedBy int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	deleteQuery := database.ConvertPlaceholders(`DELETE FROM group_role WHERE role_id = ?`)
+	if _, err = tx.Exec(deleteQuery, roleID); err != nil {
+		return fmt.Errorf("failed to clear role permissions: %w", err)
+	}
+
+	insertQuery := database.ConvertPlaceholders(`
+		INSERT INTO group_role (role_id, group_id, permission_key, permission_value, create_time, create_by, change_time, change_by)
+		VALUES (?, ?, ?, 1, ?, ?, ?, ?)
+	`)
+	now := time.Now()
+	for _, p := range perms {
+		for _, key := range p.Permissions {
+			_, err = tx.Exec(insertQuery, roleID, p.GroupID, key, now, changedBy, now, changedBy)
+			if err != nil {
+				return fmt.Errorf("failed to add role permission: %w", err)
+			}
+		}
+	}
+
+	return tx.Commit()
+}
+
+// ListEffectivePermissions returns every group permission of an agent:
+// direct group_user assignments and the group_role permissions of the
+// agent's valid roles. Each key appears once, with its sources.
+func (r *DBRoleRepository) ListEffectivePermissions(userID int) ([]*models.EffectiveGroupPermission, error) {
+	query := database.ConvertPlaceholders(`
+		SELECT gu.group_id, g.name, gu.permission_key, ''
+		FROM group_user gu
+		INNER JOIN groups g ON g.id = gu.group_id
+		WHERE gu.user_id = ?
+		UNION ALL
+		SELECT gr.group_id, g.name, gr.permission_key, r.name
+		FROM role_user ru
+		INNER JOIN roles r ON r.id = ru.role_id
+		INNER JOIN group_role gr ON gr.role_id = ru.role_id
+		INNER JOIN groups g ON g.id = gr.group_id
+		WHERE ru.user_id = ? AND r.valid_id = 1 AND gr.permission_value = 1
+		ORDER BY 2, 3, 4
+	`)
+
+	rows, err := r.db.Query(query, userID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list effective permissions: %w", err)
+	}
+	defer rows.Close()
+
+	perms := []*models.EffectiveGroupPermission{}
+	var current *models.EffectiveGroupPermission
+	for rows.Next() {
+		var groupID int
+		var groupName, key, roleName string
+		if err := rows.Scan(&groupID, &groupName, &key, &roleName); err != nil {
+			return nil, fmt.Errorf("failed to scan effective permission: %w", err)
+		}
+		if current == nil || current.GroupID != groupID || current.Permission != key {
+			current = &models.EffectiveGroupPermission{GroupID: groupID, GroupName: groupName, Permission: key}
+			perms = append(perms, current)
+		}
+		if roleName == "" {
+			current.Direct = true
+		} else {
+			current.Roles = append(current.Roles, roleName)
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read effective permissions: %w", err)
+	}
+
+	return perms, nil
+}
+
 // Helper function to convert empty strings to NULL.
 func nullString(s string) sql.NullString {
 	if s == "" {
@@ -0,0 +1,152 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/models"
+)
+
+const emailBounceSelect = `
+	SELECT id, email, bounce_type, COALESCE(status, ''), COALESCE(diagnostic, ''),
+	       hard_count, soft_count, first_bounce_time, last_bounce_time
+	FROM email_bounce`
+
+// EmailLoopRepository stores the automatic emails sent per address
+// (ticket_loop_protection, as in OTRS) and the bounce state of addresses.
+type EmailLoopRepository struct {
+	db *sql.DB
+}
+
+// NewEmailLoopRepository creates a new email loop repository.
+func NewEmailLoopRepository(db *sql.DB) *EmailLoopRepository {
+	return &EmailLoopRepository{db: db}
+}
+
+// CountSent returns how many automatic emails were sent to an address on a
+// day.
+func (r *EmailLoopRepository) CountSent(ctx context.Context, address, day string) (int, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx, database.ConvertPlaceholders(
+		"SELECT COUNT(*) FROM ticket_loop_protection WHERE sent_to = ? AND sent_date = ?"),
+		address, day).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("count loop protection: %w", err)
+	}
+	return count, nil
+}
+
+// AddSent records an automatic email sent to an address on a day. Entries
+// of other days are removed; only the current day is counted.
+func (r *EmailLoopRepository) AddSent(ctx context.Context, address, day string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(ctx, database.ConvertPlaceholders(
+		"DELETE FROM ticket_loop_protection WHERE sent_date <> ?"), day); err != nil {
+		return fmt.Errorf("expire loop protection: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, database.ConvertPlaceholders(
+		"INSERT INTO ticket_loop_protection (sent_to, sent_date) VALUES (?, ?)"), address, day); err != nil {
+		return fmt.Errorf("insert loop protection: %w", err)
+	}
+	return tx.Commit()
+}
+
+// GetBounce returns the bounce state of an address, or nil if it never
+// bounced.
+func (r *EmailLoopRepository) GetBounce(ctx context.Context, email string) (*models.EmailBounce, error) {
+	b, err := scanEmailBounce(r.db.QueryRowContext(ctx, database.ConvertPlaceholders(emailBounceSelect+" WHERE email = ?"),
+		strings.ToLower(email)))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query email bounce: %w", err)
+	}
+	return b, nil
+}
+
+// ListBounces returns the bounce state of the given addresses, or of all
+// addresses when none are given, most recent bounce first.
+func (r *EmailLoopRepository) ListBounces(ctx context.Context, emails ...string) ([]*models.EmailBounce, error) {
+	query := emailBounceSelect
+	args := make([]interface{}, 0, len(emails))
+	if len(emails) > 0 {
+		placeholders := make([]string, len(emails))
+		for i, email := range emails {
+			placeholders[i] = "?"
+			args = append(args, strings.ToLower(email))
+		}
+		query += " WHERE email IN (" + strings.Join(placeholders, ", ") + ")"
+	}
+	rows, err := r.db.QueryContext(ctx, database.ConvertPlaceholders(query+" ORDER BY last_bounce_time DESC, id DESC"), args...)
+	if err != nil {
+		return nil, fmt.Errorf("query email bounces: %w", err)
+	}
+	defer rows.Close()
+
+	bounces := []*models.EmailBounce{}
+	for rows.Next() {
+		b, err := scanEmailBounce(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan email bounce: %w", err)
+		}
+		bounces = append(bounces, b)
+	}
+	return bounces, rows.Err()
+}
+
+// SaveBounce inserts the bounce state of an address, or updates it when b.ID
+// is set.
+func (r *EmailLoopRepository) SaveBounce(ctx context.Context, b *models.EmailBounce) error {
+	b.Email = strings.ToLower(b.Email)
+	if b.ID > 0 {
+		_, err := r.db.ExecContext(ctx, database.ConvertPlaceholders(`
+			UPDATE email_bounce
+			SET bounce_type = ?, status = ?, diagnostic = ?, hard_count = ?, soft_count = ?, last_bounce_time = ?
+			WHERE id = ?`),
+			b.BounceType, nullString(b.Status), nullString(b.Diagnostic), b.HardCount, b.SoftCount, b.LastBounceTime, b.ID)
+		if err != nil {
+			return fmt.Errorf("update email bounce: %w", err)
+		}
+		return nil
+	}
+	id, err := database.GetAdapter().InsertWithReturning(r.db, database.ConvertPlaceholders(`
+		INSERT INTO email_bounce (email, bounce_type, status, diagnostic, hard_count, soft_count,
+			first_bounce_time, last_bounce_time)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?) RETURNING id`),
+		b.Email, b.BounceType, nullString(b.Status), nullString(b.Diagnostic), b.HardCount, b.SoftCount,
+		b.FirstBounceTime, b.LastBounceTime)
+	if err != nil {
+		return fmt.Errorf("insert email bounce: %w", err)
+	}
+	b.ID = int(id)
+	return nil
+}
+
+// DeleteBounce clears the bounce state of an address.
+func (r *EmailLoopRepository) DeleteBounce(ctx context.Context, email string) (bool, error) {
+	res, err := r.db.ExecContext(ctx, database.ConvertPlaceholders("DELETE FROM email_bounce WHERE email = ?"),
+		strings.ToLower(email))
+	if err != nil {
+		return false, fmt.Errorf("delete email bounce: %w", err)
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func scanEmailBounce(row kbRowScanner) (*models.EmailBounce, error) {
+	var b models.EmailBounce
+	if err := row.Scan(&b.ID, &b.Email, &b.BounceType, &b.Status, &b.Diagnostic,
+		&b.HardCount, &b.SoftCount, &b.FirstBounceTime, &b.LastBounceTime); err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
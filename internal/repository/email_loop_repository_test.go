@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/testutil"
+)
+
+func TestEmailLoopRepository(t *testing.T) {
+	db := testutil.UseMigratedDB(t)
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+	repo := NewEmailLoopRepository(db)
+
+	require.NoError(t, repo.AddSent(ctx, "jane@example.com", "2026-3-9"))
+	require.NoError(t, repo.AddSent(ctx, "jane@example.com", "2026-3-10"))
+	require.NoError(t, repo.AddSent(ctx, "jane@example.com", "2026-3-10"))
+	count, err := repo.CountSent(ctx, "jane@example.com", "2026-3-10")
+	require.NoError(t, err)
+	assert.Equal(t, 2, count)
+	count, err = repo.CountSent(ctx, "jane@example.com", "2026-3-9")
+	require.NoError(t, err)
+	assert.Zero(t, count, "other days are expired")
+
+	missing, err := repo.GetBounce(ctx, "jane@example.com")
+	require.NoError(t, err)
+	assert.Nil(t, missing)
+
+	b := &models.EmailBounce{Email: "Jane@Example.com", BounceType: models.BounceTypeSoft, Status: "4.2.2",
+		SoftCount: 1, FirstBounceTime: now, LastBounceTime: now}
+	require.NoError(t, repo.SaveBounce(ctx, b))
+	require.NotZero(t, b.ID)
+	b.BounceType, b.HardCount, b.Diagnostic = models.BounceTypeHard, 1, "550 user unknown"
+	b.LastBounceTime = now.Add(time.Hour)
+	require.NoError(t, repo.SaveBounce(ctx, b))
+	other := &models.EmailBounce{Email: "bob@example.com", BounceType: models.BounceTypeSoft, SoftCount: 1,
+		FirstBounceTime: now, LastBounceTime: now}
+	require.NoError(t, repo.SaveBounce(ctx, other))
+
+	got, err := repo.GetBounce(ctx, "JANE@example.com")
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	assert.Equal(t, "jane@example.com", got.Email)
+	assert.Equal(t, models.BounceTypeHard, got.BounceType)
+	assert.Equal(t, "550 user unknown", got.Diagnostic)
+	assert.Equal(t, 1, got.HardCount)
+	assert.Equal(t, 1, got.SoftCount)
+
+	bounces, err := repo.ListBounces(ctx)
+	require.NoError(t, err)
+	require.Len(t, bounces, 2)
+	assert.Equal(t, "jane@example.com", bounces[0].Email, "most recent bounce first")
+	bounces, err = repo.ListBounces(ctx, "bob@example.com", "nobody@example.com")
+	require.NoError(t, err)
+	require.Len(t, bounces, 1)
+
+	deleted, err := repo.DeleteBounce(ctx, "jane@example.com")
+	require.NoError(t, err)
+	assert.True(t, deleted)
+	deleted, err = repo.DeleteBounce(ctx, "jane@example.com")
+	require.NoError(t, err)
+	assert.False(t, deleted)
+}
@@ -0,0 +1,296 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/models"
+)
+
+const kbArticleSelect = `
+	SELECT a.id, a.category_id, COALESCE(c.name, ''), a.title, a.body,
+	       COALESCE(a.keywords, ''), a.visibility, a.version, a.valid_id,
+	       a.create_time, a.create_by, a.change_time, a.change_by
+	FROM kb_article a
+	LEFT JOIN kb_category c ON c.id = a.category_id`
+
+// KBRepository handles database operations for the knowledge base.
+type KBRepository struct {
+	db *sql.DB
+}
+
+// NewKBRepository creates a new knowledge base repository.
+func NewKBRepository(db *sql.DB) *KBRepository {
+	return &KBRepository{db: db}
+}
+
+// ListCategories returns all valid categories ordered by name.
+func (r *KBRepository) ListCategories(ctx context.Context) ([]models.KBCategory, error) {
+	rows, err := r.db.QueryContext(ctx, database.ConvertPlaceholders(`
+		SELECT id, name, parent_id, COALESCE(comments, ''), valid_id,
+		       create_time, create_by, change_time, change_by
+		FROM kb_category
+		WHERE valid_id = 1
+		ORDER BY name
+	`))
+	if err != nil {
+		return nil, fmt.Errorf("query kb categories: %w", err)
+	}
+	defer rows.Close()
+
+	categories := make([]models.KBCategory, 0)
+	for rows.Next() {
+		var cat models.KBCategory
+		var parentID sql.NullInt64
+		if err := rows.Scan(&cat.ID, &cat.Name, &parentID, &cat.Comments, &cat.ValidID,
+			&cat.CreateTime, &cat.CreateBy, &cat.ChangeTime, &cat.ChangeBy); err != nil {
+			return nil, fmt.Errorf("scan kb category: %w", err)
+		}
+		if parentID.Valid {
+			pid := int(parentID.Int64)
+			cat.ParentID = &pid
+		}
+		categories = append(categories, cat)
+	}
+	return categories, rows.Err()
+}
+
+// CategoryExists reports whether a valid category with the given ID exists.
+func (r *KBRepository) CategoryExists(ctx context.Context, id int) (bool, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx, database.ConvertPlaceholders(
+		"SELECT COUNT(*) FROM kb_category WHERE id = ? AND valid_id = 1",
+	), id).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("check kb category: %w", err)
+	}
+	return count > 0, nil
+}
+
+// CreateCategory inserts a category and returns its ID.
+func (r *KBRepository) CreateCategory(ctx context.Context, cat *models.KBCategory) (int, error) {
+	now := time.Now()
+	query := database.ConvertPlaceholders(`
+		INSERT INTO kb_category (name, parent_id, comments, valid_id, create_time, create_by, change_time, change_by)
+		VALUES (?, ?, ?, 1, ?, ?, ?, ?)
+		RETURNING id`)
+
+	var parentID interface{}
+	if cat.ParentID != nil {
+		parentID = *cat.ParentID
+	}
+	id, err := database.GetAdapter().InsertWithReturning(r.db, query,
+		cat.Name, parentID, cat.Comments, now, cat.CreateBy, now, cat.CreateBy)
+	if err != nil {
+		return 0, fmt.Errorf("insert kb category: %w", err)
+	}
+	return int(id), nil
+}
+
+// GetArticle returns an article by ID, or nil if it does not exist.
+func (r *KBRepository) GetArticle(ctx context.Context, id int) (*models.KBArticle, error) {
+	row := r.db.QueryRowContext(ctx, database.ConvertPlaceholders(kbArticleSelect+" WHERE a.id = ? AND a.valid_id = 1"), id)
+	article, err := scanKBArticle(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get kb article: %w", err)
+	}
+	return article, nil
+}
+
+// ListArticles returns valid articles matching the filter, most recently changed first.
+func (r *KBRepository) ListArticles(ctx context.Context, filter models.KBArticleFilter) ([]models.KBArticle, error) {
+	where := []string{"a.valid_id = 1"}
+	var args []interface{}
+
+	if filter.CategoryID > 0 {
+		where = append(where, "a.category_id = ?")
+		args = append(args, filter.CategoryID)
+	}
+	if len(filter.Visibilities) > 0 {
+		placeholders := make([]string, len(filter.Visibilities))
+		for i, v := range filter.Visibilities {
+			placeholders[i] = "?"
+			args = append(args, string(v))
+		}
+		where = append(where, "a.visibility IN ("+strings.Join(placeholders, ",")+")")
+	}
+	for _, term := range strings.Fields(strings.ToLower(filter.Query)) {
+		like := "%" + term + "%"
+		where = append(where, "(LOWER(a.title) LIKE ? OR LOWER(a.keywords) LIKE ? OR LOWER(a.body) LIKE ?)")
+		args = append(args, like, like, like)
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = 50
+	}
+	query := kbArticleSelect + " WHERE " + strings.Join(where, " AND ") +
+		" ORDER BY a.change_time DESC LIMIT ? OFFSET ?"
+	args = append(args, limit, filter.Offset)
+
+	rows, err := r.db.QueryContext(ctx, database.ConvertPlaceholders(query), args...)
+	if err != nil {
+		return nil, fmt.Errorf("query kb articles: %w", err)
+	}
+	defer rows.Close()
+
+	articles := make([]models.KBArticle, 0)
+	for rows.Next() {
+		article, err := scanKBArticle(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan kb article: %w", err)
+		}
+		articles = append(articles, *article)
+	}
+	return articles, rows.Err()
+}
+
+// CreateArticle inserts an article and its first version in one transaction.
+func (r *KBRepository) CreateArticle(ctx context.Context, article *models.KBArticle, changeNote string) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	now := time.Now()
+	query := database.ConvertPlaceholders(`
+		INSERT INTO kb_article (category_id, title, body, keywords, visibility, version,
+			valid_id, create_time, create_by, change_time, change_by)
+		VALUES (?, ?, ?, ?, ?, 1, 1, ?, ?, ?, ?)
+		RETURNING id`)
+	id, err := database.GetAdapter().InsertWithReturningTx(tx, query,
+		article.CategoryID, article.Title, article.Body, article.Keywords, string(article.Visibility),
+		now, article.CreateBy, now, article.CreateBy)
+	if err != nil {
+		return 0, fmt.Errorf("insert kb article: %w", err)
+	}
+
+	article.ID = int(id)
+	article.Version = 1
+	if err := insertKBVersion(ctx, tx, article, changeNote, article.CreateBy, now); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("commit kb article: %w", err)
+	}
+	return article.ID, nil
+}
+
+// UpdateArticle stores a new version of an article. The version number is
+// bumped and the previous content stays available in kb_article_version.
+func (r *KBRepository) UpdateArticle(ctx context.Context, article *models.KBArticle, changeNote string, userID int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var current int
+	err = tx.QueryRowContext(ctx, database.ConvertPlaceholders(
+		"SELECT version FROM kb_article WHERE id = ? AND valid_id = 1",
+	), article.ID).Scan(&current)
+	if err != nil {
+		return fmt.Errorf("load kb article version: %w", err)
+	}
+
+	now := time.Now()
+	article.Version = current + 1
+	_, err = tx.ExecContext(ctx, database.ConvertPlaceholders(`
+		UPDATE kb_article
+		SET category_id = ?, title = ?, body = ?, keywords = ?, visibility = ?,
+		    version = ?, change_time = ?, change_by = ?
+		WHERE id = ? AND version = ?
+	`), article.CategoryID, article.Title, article.Body, article.Keywords, string(article.Visibility),
+		article.Version, now, userID, article.ID, current)
+	if err != nil {
+		return fmt.Errorf("update kb article: %w", err)
+	}
+
+	if err := insertKBVersion(ctx, tx, article, changeNote, userID, now); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit kb article: %w", err)
+	}
+	return nil
+}
+
+// InvalidateArticle soft-deletes an article by marking it invalid.
+func (r *KBRepository) InvalidateArticle(ctx context.Context, id int, userID int) error {
+	result, err := r.db.ExecContext(ctx, database.ConvertPlaceholders(`
+		UPDATE kb_article SET valid_id = 2, change_time = ?, change_by = ?
+		WHERE id = ? AND valid_id = 1
+	`), time.Now(), userID, id)
+	if err != nil {
+		return fmt.Errorf("invalidate kb article: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// ListVersions returns all saved versions of an article, newest first.
+func (r *KBRepository) ListVersions(ctx context.Context, articleID int) ([]models.KBArticleVersion, error) {
+	rows, err := r.db.QueryContext(ctx, database.ConvertPlaceholders(`
+		SELECT id, article_id, version, title, body, COALESCE(keywords, ''), visibility,
+		       COALESCE(change_note, ''), create_time, create_by
+		FROM kb_article_version
+		WHERE article_id = ?
+		ORDER BY version DESC
+	`), articleID)
+	if err != nil {
+		return nil, fmt.Errorf("query kb article versions: %w", err)
+	}
+	defer rows.Close()
+
+	versions := make([]models.KBArticleVersion, 0)
+	for rows.Next() {
+		var v models.KBArticleVersion
+		var visibility string
+		if err := rows.Scan(&v.ID, &v.ArticleID, &v.Version, &v.Title, &v.Body, &v.Keywords,
+			&visibility, &v.ChangeNote, &v.CreateTime, &v.CreateBy); err != nil {
+			return nil, fmt.Errorf("scan kb article version: %w", err)
+		}
+		v.Visibility = models.KBVisibility(visibility)
+		versions = append(versions, v)
+	}
+	return versions, rows.Err()
+}
+
+func insertKBVersion(ctx context.Context, tx *sql.Tx, article *models.KBArticle, changeNote string, userID int, now time.Time) error {
+	_, err := tx.ExecContext(ctx, database.ConvertPlaceholders(`
+		INSERT INTO kb_article_version (article_id, version, title, body, keywords, visibility,
+			change_note, create_time, create_by)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`), article.ID, article.Version, article.Title, article.Body, article.Keywords,
+		string(article.Visibility), changeNote, now, userID)
+	if err != nil {
+		return fmt.Errorf("insert kb article version: %w", err)
+	}
+	return nil
+}
+
+type kbRowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanKBArticle(row kbRowScanner) (*models.KBArticle, error) {
+	var a models.KBArticle
+	var visibility string
+	if err := row.Scan(&a.ID, &a.CategoryID, &a.CategoryName, &a.Title, &a.Body, &a.Keywords,
+		&visibility, &a.Version, &a.ValidID, &a.CreateTime, &a.CreateBy, &a.ChangeTime, &a.ChangeBy); err != nil {
+		return nil, err
+	}
+	a.Visibility = models.KBVisibility(visibility)
+	return &a, nil
+}
@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/models"
+)
+
+// LanguagePackRepository stores the language packs imported at runtime.
+type LanguagePackRepository struct {
+	db *sql.DB
+}
+
+// NewLanguagePackRepository creates a new language pack repository.
+func NewLanguagePackRepository(db *sql.DB) *LanguagePackRepository {
+	return &LanguagePackRepository{db: db}
+}
+
+// List returns all packs with their entries, sorted by language.
+func (r *LanguagePackRepository) List(ctx context.Context) ([]*models.LanguagePack, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, language, content, entry_count, checksum, create_time, create_by
+		FROM language_pack ORDER BY language`)
+	if err != nil {
+		return nil, fmt.Errorf("query language packs: %w", err)
+	}
+	defer rows.Close()
+
+	packs := []*models.LanguagePack{}
+	for rows.Next() {
+		p, err := scanLanguagePack(rows)
+		if err != nil {
+			return nil, err
+		}
+		packs = append(packs, p)
+	}
+	return packs, rows.Err()
+}
+
+// Get returns the pack of a language, or nil if it has none.
+func (r *LanguagePackRepository) Get(ctx context.Context, lang string) (*models.LanguagePack, error) {
+	row := r.db.QueryRowContext(ctx, database.ConvertPlaceholders(`
+		SELECT id, language, content, entry_count, checksum, create_time, create_by
+		FROM language_pack WHERE language = ?`), lang)
+	p, err := scanLanguagePack(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return p, err
+}
+
+// Signatures returns the checksum of each language's pack, so callers can
+// detect changes without loading the content.
+func (r *LanguagePackRepository) Signatures(ctx context.Context) (map[string]string, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT language, checksum FROM language_pack")
+	if err != nil {
+		return nil, fmt.Errorf("query language pack checksums: %w", err)
+	}
+	defer rows.Close()
+
+	sigs := make(map[string]string)
+	for rows.Next() {
+		var lang, checksum string
+		if err := rows.Scan(&lang, &checksum); err != nil {
+			return nil, fmt.Errorf("scan language pack checksum: %w", err)
+		}
+		sigs[lang] = checksum
+	}
+	return sigs, rows.Err()
+}
+
+// Save stores a pack, replacing the language's previous pack.
+func (r *LanguagePackRepository) Save(ctx context.Context, p *models.LanguagePack) error {
+	content, err := json.Marshal(p.Entries)
+	if err != nil {
+		return fmt.Errorf("encode language pack: %w", err)
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(ctx, database.ConvertPlaceholders(
+		"DELETE FROM language_pack WHERE language = ?"), p.Language); err != nil {
+		return fmt.Errorf("replace language pack: %w", err)
+	}
+	id, err := database.GetAdapter().InsertWithReturningTx(tx, database.ConvertPlaceholders(`
+		INSERT INTO language_pack (language, content, entry_count, checksum, create_time, create_by)
+		VALUES (?, ?, ?, ?, ?, ?) RETURNING id`),
+		p.Language, string(content), p.EntryCount, p.Checksum, p.CreateTime, p.CreateBy)
+	if err != nil {
+		return fmt.Errorf("insert language pack: %w", err)
+	}
+	p.ID = int(id)
+	return tx.Commit()
+}
+
+// Delete removes the pack of a language.
+func (r *LanguagePackRepository) Delete(ctx context.Context, lang string) (bool, error) {
+	res, err := r.db.ExecContext(ctx, database.ConvertPlaceholders(
+		"DELETE FROM language_pack WHERE language = ?"), lang)
+	if err != nil {
+		return false, fmt.Errorf("delete language pack: %w", err)
+	}
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func scanLanguagePack(row interface{ Scan(...interface{}) error }) (*models.LanguagePack, error) {
+	var (
+		p       models.LanguagePack
+		content string
+	)
+	if err := row.Scan(&p.ID, &p.Language, &content, &p.EntryCount, &p.Checksum, &p.CreateTime, &p.CreateBy); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, err
+		}
+		return nil, fmt.Errorf("scan language pack: %w", err)
+	}
+	if err := json.Unmarshal([]byte(content), &p.Entries); err != nil {
+		return nil, fmt.Errorf("decode language pack %s: %w", p.Language, err)
+	}
+	return &p, nil
+}
@@ -50,7 +50,7 @@ func (r *MemoryTicketTemplateRepository) GetTemplateByID(ctx context.Context, id
 
 	template, exists := r.templates[id]
 	if !exists {
-		return nil, fmt.Errorf("template with ID %d not found", id)
+		return nil, fmt.Errorf("%w: %d", ErrTicketTemplateNotFound, id)
 	}
 
 	// Return a copy
@@ -58,6 +58,23 @@ func (r *MemoryTicketTemplateRepository) GetTemplateByID(ctx context.Context, id
 	return &result, nil
 }
 
+// GetAllTemplates retrieves all templates, including inactive ones.
+func (r *MemoryTicketTemplateRepository) GetAllTemplates(ctx context.Context) ([]models.TicketTemplate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var templates []models.TicketTemplate
+	for _, tmpl := range r.templates {
+		templates = append(templates, *tmpl)
+	}
+
+	sort.Slice(templates, func(i, j int) bool {
+		return templates[i].Name < templates[j].Name
+	})
+
+	return templates, nil
+}
+
 // GetActiveTemplates retrieves all active templates.
 func (r *MemoryTicketTemplateRepository) GetActiveTemplates(ctx context.Context) ([]models.TicketTemplate, error) {
 	r.mu.RLock()
@@ -78,6 +95,36 @@ func (r *MemoryTicketTemplateRepository) GetActiveTemplates(ctx context.Context)
 	return templates, nil
 }
 
+// GetTemplatesForUser retrieves the active templates an agent may use. The
+// in-memory repository knows no group memberships, so only templates
+// without groups are returned.
+func (r *MemoryTicketTemplateRepository) GetTemplatesForUser(ctx context.Context, userID int) ([]models.TicketTemplate, error) {
+	active, err := r.GetActiveTemplates(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var templates []models.TicketTemplate
+	for _, tmpl := range active {
+		if len(tmpl.GroupIDs) == 0 {
+			templates = append(templates, tmpl)
+		}
+	}
+	return templates, nil
+}
+
+// TemplateNameExists reports whether another template already uses the name.
+func (r *MemoryTicketTemplateRepository) TemplateNameExists(ctx context.Context, name string, excludeID uint) (bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for id, tmpl := range r.templates {
+		if id != excludeID && tmpl.Name == name {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
 // GetTemplatesByCategory retrieves templates by category.
 func (r *MemoryTicketTemplateRepository) GetTemplatesByCategory(ctx context.Context, category string) ([]models.TicketTemplate, error) {
 	r.mu.RLock()
@@ -105,7 +152,7 @@ func (r *MemoryTicketTemplateRepository) UpdateTemplate(ctx context.Context, tem
 
 	existing, exists := r.templates[template.ID]
 	if !exists {
-		return fmt.Errorf("template with ID %d not found", template.ID)
+		return fmt.Errorf("%w: %d", ErrTicketTemplateNotFound, template.ID)
 	}
 
 	// Preserve creation time
@@ -125,7 +172,7 @@ func (r *MemoryTicketTemplateRepository) DeleteTemplate(ctx context.Context, id
 	defer r.mu.Unlock()
 
 	if _, exists := r.templates[id]; !exists {
-		return fmt.Errorf("template with ID %d not found", id)
+		return fmt.Errorf("%w: %d", ErrTicketTemplateNotFound, id)
 	}
 
 	delete(r.templates, id)
@@ -139,7 +186,7 @@ func (r *MemoryTicketTemplateRepository) IncrementUsageCount(ctx context.Context
 
 	template, exists := r.templates[templateID]
 	if !exists {
-		return fmt.Errorf("template with ID %d not found", templateID)
+		return fmt.Errorf("%w: %d", ErrTicketTemplateNotFound, templateID)
 	}
 
 	template.UsageCount++
@@ -0,0 +1,275 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/models"
+)
+
+// ErrPasswordResetUsed is returned when a reset link was used or replaced
+// in the meantime.
+var ErrPasswordResetUsed = errors.New("password reset link was already used")
+
+// Account types of password resets, as in password_history.
+const (
+	passwordResetAgent    = "agent"
+	passwordResetCustomer = "customer"
+)
+
+// PasswordResetAccount is the part of an agent or customer account a
+// password reset needs.
+type PasswordResetAccount struct {
+	Login     string
+	Email     string // Empty when the account has no address to mail
+	FirstName string
+	ValidID   int
+}
+
+// PasswordResetRepository stores forgot-password requests and lockouts and
+// sets the new passwords of agents and customers.
+type PasswordResetRepository struct {
+	db *sql.DB
+}
+
+// NewPasswordResetRepository creates a new password reset repository.
+func NewPasswordResetRepository(db *sql.DB) *PasswordResetRepository {
+	return &PasswordResetRepository{db: db}
+}
+
+// Account returns the agent or customer a reset is asked for, or nil if
+// there is none. Agents are found by login, which is their address when it
+// is an email address; customers by login, then by email.
+func (r *PasswordResetRepository) Account(ctx context.Context, userType, name string) (*PasswordResetAccount, error) {
+	var queries []string
+	switch userType {
+	case passwordResetAgent:
+		queries = []string{"SELECT login, '', first_name, valid_id FROM users WHERE login = ?"}
+	case passwordResetCustomer:
+		queries = []string{
+			"SELECT login, COALESCE(email, ''), first_name, valid_id FROM customer_user WHERE login = ?",
+			"SELECT login, COALESCE(email, ''), first_name, valid_id FROM customer_user WHERE LOWER(email) = ? ORDER BY id LIMIT 1",
+		}
+	default:
+		return nil, fmt.Errorf("unknown account type %q", userType)
+	}
+	args := []interface{}{name, strings.ToLower(name)}
+	for i, query := range queries {
+		var acct PasswordResetAccount
+		err := r.db.QueryRowContext(ctx, database.ConvertPlaceholders(query), args[i]).
+			Scan(&acct.Login, &acct.Email, &acct.FirstName, &acct.ValidID)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("query %s account: %w", userType, err)
+		}
+		if userType == passwordResetAgent && strings.Contains(acct.Login, "@") {
+			acct.Email = acct.Login
+		}
+		return &acct, nil
+	}
+	return nil, nil
+}
+
+// CountRequests returns how many resets were requested for the login since
+// the given time.
+func (r *PasswordResetRepository) CountRequests(ctx context.Context, userType, login string, since time.Time) (int, error) {
+	var n int
+	err := r.db.QueryRowContext(ctx, database.ConvertPlaceholders(`
+		SELECT COUNT(*) FROM password_reset_request
+		WHERE user_type = ? AND login = ? AND create_time > ?`),
+		userType, login, since).Scan(&n)
+	if err != nil {
+		return 0, fmt.Errorf("count password reset requests: %w", err)
+	}
+	return n, nil
+}
+
+// Create records a request. tokenHash is empty when no link was sent; the
+// earlier links of the login stop working either way.
+func (r *PasswordResetRepository) Create(ctx context.Context, req *models.PasswordResetRequest, tokenHash string) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin password reset request: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(ctx, database.ConvertPlaceholders(`
+		UPDATE password_reset_request SET token_hash = NULL
+		WHERE user_type = ? AND login = ? AND token_hash IS NOT NULL`),
+		req.UserType, req.Login); err != nil {
+		return fmt.Errorf("replace password reset links: %w", err)
+	}
+	var hash interface{}
+	if tokenHash != "" {
+		hash = tokenHash
+	}
+	id, err := database.GetAdapter().InsertWithReturningTx(tx, database.ConvertPlaceholders(`
+		INSERT INTO password_reset_request (user_type, login, token_hash, remote_ip, expires_at, create_time)
+		VALUES (?, ?, ?, ?, ?, ?)
+		RETURNING id`),
+		req.UserType, req.Login, hash, req.RemoteIP, req.ExpiresAt, req.CreateTime)
+	if err != nil {
+		return fmt.Errorf("insert password reset request: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit password reset request: %w", err)
+	}
+	req.ID = id
+	return nil
+}
+
+// GetByTokenHash returns the request whose link is still usable with the
+// token hash, or nil if there is none.
+func (r *PasswordResetRepository) GetByTokenHash(ctx context.Context, tokenHash string) (*models.PasswordResetRequest, error) {
+	var (
+		req    models.PasswordResetRequest
+		usedAt sql.NullTime
+	)
+	err := r.db.QueryRowContext(ctx, database.ConvertPlaceholders(`
+		SELECT id, user_type, login, COALESCE(remote_ip, ''), expires_at, used_at, create_time
+		FROM password_reset_request WHERE token_hash = ?`), tokenHash).
+		Scan(&req.ID, &req.UserType, &req.Login, &req.RemoteIP, &req.ExpiresAt, &usedAt, &req.CreateTime)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query password reset request: %w", err)
+	}
+	if usedAt.Valid {
+		req.UsedAt = &usedAt.Time
+	}
+	return &req, nil
+}
+
+// Consume sets the account's new password hash and uses up the request's
+// link, in one transaction. Any lockout of the account ends with it.
+func (r *PasswordResetRepository) Consume(ctx context.Context, req *models.PasswordResetRequest, hash string, now time.Time) error {
+	var update string
+	switch req.UserType {
+	case passwordResetAgent:
+		update = "UPDATE users SET pw = ?, change_time = ?, change_by = id WHERE login = ? AND valid_id = 1"
+	case passwordResetCustomer:
+		update = "UPDATE customer_user SET pw = ?, change_time = ? WHERE login = ? AND valid_id = 1"
+	default:
+		return fmt.Errorf("unknown account type %q", req.UserType)
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin password reset: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	res, err := tx.ExecContext(ctx, database.ConvertPlaceholders(`
+		UPDATE password_reset_request SET token_hash = NULL, used_at = ?
+		WHERE id = ? AND token_hash IS NOT NULL AND used_at IS NULL`), now, req.ID)
+	if err != nil {
+		return fmt.Errorf("use password reset link: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrPasswordResetUsed
+	}
+	res, err = tx.ExecContext(ctx, database.ConvertPlaceholders(update), hash, now, req.Login)
+	if err != nil {
+		return fmt.Errorf("set %s password: %w", req.UserType, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrPasswordResetUsed
+	}
+	if _, err := tx.ExecContext(ctx, database.ConvertPlaceholders(
+		"DELETE FROM password_reset_lockout WHERE user_type = ? AND login = ?"), req.UserType, req.Login); err != nil {
+		return fmt.Errorf("delete password reset lockout: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit password reset: %w", err)
+	}
+	used := now
+	req.UsedAt = &used
+	return nil
+}
+
+// LockedUntil returns when the last lockout of a login ends or ended, or
+// the zero time when it was never locked out.
+func (r *PasswordResetRepository) LockedUntil(ctx context.Context, userType, login string) (time.Time, error) {
+	var until time.Time
+	err := r.db.QueryRowContext(ctx, database.ConvertPlaceholders(`
+		SELECT locked_until FROM password_reset_lockout
+		WHERE user_type = ? AND login = ?`),
+		userType, login).Scan(&until)
+	if err == sql.ErrNoRows {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("query password reset lockout: %w", err)
+	}
+	return until, nil
+}
+
+// Lock locks a login out of password resets until the given time.
+func (r *PasswordResetRepository) Lock(ctx context.Context, userType, login string, until, now time.Time) error {
+	res, err := r.db.ExecContext(ctx, database.ConvertPlaceholders(`
+		UPDATE password_reset_lockout SET locked_until = ?, create_time = ?
+		WHERE user_type = ? AND login = ?`), until, now, userType, login)
+	if err != nil {
+		return fmt.Errorf("update password reset lockout: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		return nil
+	}
+	if _, err := r.db.ExecContext(ctx, database.ConvertPlaceholders(`
+		INSERT INTO password_reset_lockout (user_type, login, locked_until, create_time)
+		VALUES (?, ?, ?, ?)`), userType, login, until, now); err != nil {
+		return fmt.Errorf("insert password reset lockout: %w", err)
+	}
+	return nil
+}
+
+// Lockouts returns the lockouts in force at now, those ending first first.
+func (r *PasswordResetRepository) Lockouts(ctx context.Context, now time.Time) ([]models.PasswordResetLockout, error) {
+	rows, err := r.db.QueryContext(ctx, database.ConvertPlaceholders(`
+		SELECT user_type, login, locked_until, create_time FROM password_reset_lockout
+		WHERE locked_until > ?
+		ORDER BY locked_until, user_type, login`), now)
+	if err != nil {
+		return nil, fmt.Errorf("query password reset lockouts: %w", err)
+	}
+	defer rows.Close()
+
+	list := []models.PasswordResetLockout{}
+	for rows.Next() {
+		var l models.PasswordResetLockout
+		if err := rows.Scan(&l.UserType, &l.Login, &l.LockedUntil, &l.CreateTime); err != nil {
+			return nil, fmt.Errorf("scan password reset lockout: %w", err)
+		}
+		list = append(list, l)
+	}
+	return list, rows.Err()
+}
+
+// Unlock ends the lockout of a login, returning false if there was none.
+func (r *PasswordResetRepository) Unlock(ctx context.Context, userType, login string) (bool, error) {
+	res, err := r.db.ExecContext(ctx, database.ConvertPlaceholders(
+		"DELETE FROM password_reset_lockout WHERE user_type = ? AND login = ?"), userType, login)
+	if err != nil {
+		return false, fmt.Errorf("delete password reset lockout: %w", err)
+	}
+	n, _ := res.RowsAffected()
+	return n > 0, nil
+}
+
+// DeleteBefore removes requests made before the given time, keeping the
+// table small; they no longer count toward any limit.
+func (r *PasswordResetRepository) DeleteBefore(ctx context.Context, before time.Time) error {
+	if _, err := r.db.ExecContext(ctx, database.ConvertPlaceholders(
+		"DELETE FROM password_reset_request WHERE create_time < ? AND expires_at < ?"), before, before); err != nil {
+		return fmt.Errorf("delete password reset requests: %w", err)
+	}
+	return nil
+}
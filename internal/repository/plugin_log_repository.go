@@ -0,0 +1,133 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/models"
+)
+
+// PluginLogRepository stores plugin log entries beyond the in-memory buffer.
+type PluginLogRepository struct {
+	db *sql.DB
+}
+
+// NewPluginLogRepository creates a new plugin log repository.
+func NewPluginLogRepository(db *sql.DB) *PluginLogRepository {
+	return &PluginLogRepository{db: db}
+}
+
+// Insert stores entries in one transaction.
+func (r *PluginLogRepository) Insert(ctx context.Context, entries []models.PluginLogEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin plugin log transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	stmt, err := tx.PrepareContext(ctx, database.ConvertPlaceholders(`
+		INSERT INTO plugin_log (plugin, level, message, fields, create_time)
+		VALUES (?, ?, ?, ?, ?)`))
+	if err != nil {
+		return fmt.Errorf("prepare plugin log insert: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, e := range entries {
+		var fields sql.NullString
+		if len(e.Fields) > 0 {
+			data, err := json.Marshal(e.Fields)
+			if err != nil {
+				data, _ = json.Marshal(map[string]string{"error": "fields not serializable: " + err.Error()})
+			}
+			fields = sql.NullString{String: string(data), Valid: true}
+		}
+		if _, err := stmt.ExecContext(ctx, e.Plugin, e.Level, e.Message, fields, e.Timestamp.UTC()); err != nil {
+			return fmt.Errorf("insert plugin log: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// Query returns the entries matching q, newest first. Levels lists the
+// levels to include; empty includes all.
+func (r *PluginLogRepository) Query(ctx context.Context, q models.PluginLogQuery, levels []string) ([]models.PluginLogEntry, error) {
+	where := []string{"plugin = ?"}
+	args := []any{q.Plugin}
+	if len(levels) > 0 {
+		where = append(where, "level IN (?"+strings.Repeat(", ?", len(levels)-1)+")")
+		for _, l := range levels {
+			args = append(args, l)
+		}
+	}
+	if !q.From.IsZero() {
+		where = append(where, "create_time >= ?")
+		args = append(args, q.From.UTC())
+	}
+	if !q.To.IsZero() {
+		where = append(where, "create_time < ?")
+		args = append(args, q.To.UTC())
+	}
+	args = append(args, q.Limit)
+
+	rows, err := r.db.QueryContext(ctx, database.ConvertPlaceholders(`
+		SELECT id, plugin, level, message, fields, create_time
+		FROM plugin_log
+		WHERE `+strings.Join(where, " AND ")+`
+		ORDER BY create_time DESC, id DESC
+		LIMIT ?`), args...)
+	if err != nil {
+		return nil, fmt.Errorf("query plugin log: %w", err)
+	}
+	defer rows.Close()
+
+	entries := make([]models.PluginLogEntry, 0)
+	for rows.Next() {
+		var e models.PluginLogEntry
+		var fields sql.NullString
+		if err := rows.Scan(&e.ID, &e.Plugin, &e.Level, &e.Message, &fields, &e.Timestamp); err != nil {
+			return nil, fmt.Errorf("scan plugin log: %w", err)
+		}
+		if fields.Valid && fields.String != "" {
+			_ = json.Unmarshal([]byte(fields.String), &e.Fields)
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// DeleteBefore removes a plugin's entries written before the given time.
+func (r *PluginLogRepository) DeleteBefore(ctx context.Context, plugin string, before time.Time) (int64, error) {
+	res, err := r.db.ExecContext(ctx, database.ConvertPlaceholders(
+		"DELETE FROM plugin_log WHERE plugin = ? AND create_time < ?"), plugin, before.UTC())
+	if err != nil {
+		return 0, fmt.Errorf("delete plugin log: %w", err)
+	}
+	return res.RowsAffected()
+}
+
+// DeleteOthersBefore removes the entries of all plugins except the given
+// ones written before the given time.
+func (r *PluginLogRepository) DeleteOthersBefore(ctx context.Context, except []string, before time.Time) (int64, error) {
+	query := "DELETE FROM plugin_log WHERE create_time < ?"
+	args := []any{before.UTC()}
+	if len(except) > 0 {
+		query += " AND plugin NOT IN (?" + strings.Repeat(", ?", len(except)-1) + ")"
+		for _, p := range except {
+			args = append(args, p)
+		}
+	}
+	res, err := r.db.ExecContext(ctx, database.ConvertPlaceholders(query), args...)
+	if err != nil {
+		return 0, fmt.Errorf("delete plugin log: %w", err)
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,308 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/models"
+)
+
+const portalDomainSelect = `
+	SELECT id, domain, customer_id, COALESCE(theme, ''), COALESCE(logo_url, ''), COALESCE(sso_provider_id, 0),
+	       password_login, cert_status, COALESCE(cert_message, ''), cert_change_time, valid_id,
+	       create_time, create_by, change_time, change_by
+	FROM customer_portal_domain`
+
+// PortalDomainRepository handles database operations for customer portal
+// domains.
+type PortalDomainRepository struct {
+	db *sql.DB
+}
+
+// NewPortalDomainRepository creates a new portal domain repository.
+func NewPortalDomainRepository(db *sql.DB) *PortalDomainRepository {
+	return &PortalDomainRepository{db: db}
+}
+
+// List returns every portal domain ordered by domain name.
+func (r *PortalDomainRepository) List(ctx context.Context) ([]models.PortalDomain, error) {
+	rows, err := r.db.QueryContext(ctx, database.ConvertPlaceholders(portalDomainSelect+" ORDER BY domain"))
+	if err != nil {
+		return nil, fmt.Errorf("query portal domains: %w", err)
+	}
+	defer rows.Close()
+
+	var domains []*models.PortalDomain
+	for rows.Next() {
+		d, err := scanPortalDomain(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan portal domain: %w", err)
+		}
+		domains = append(domains, d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if err := r.loadQueues(ctx, domains); err != nil {
+		return nil, err
+	}
+
+	result := make([]models.PortalDomain, 0, len(domains))
+	for _, d := range domains {
+		result = append(result, *d)
+	}
+	return result, nil
+}
+
+// Get returns a portal domain by ID, or nil if it does not exist.
+func (r *PortalDomainRepository) Get(ctx context.Context, id int) (*models.PortalDomain, error) {
+	return r.getWhere(ctx, "id = ?", id)
+}
+
+// GetByDomain returns the portal domain for a normalized host name, or nil.
+func (r *PortalDomainRepository) GetByDomain(ctx context.Context, domain string) (*models.PortalDomain, error) {
+	return r.getWhere(ctx, "domain = ?", domain)
+}
+
+func (r *PortalDomainRepository) getWhere(ctx context.Context, where string, arg interface{}) (*models.PortalDomain, error) {
+	row := r.db.QueryRowContext(ctx, database.ConvertPlaceholders(portalDomainSelect+" WHERE "+where), arg)
+	d, err := scanPortalDomain(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get portal domain: %w", err)
+	}
+	if err := r.loadQueues(ctx, []*models.PortalDomain{d}); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// loadQueues fills in the visible queues of the domains in portal order.
+func (r *PortalDomainRepository) loadQueues(ctx context.Context, domains []*models.PortalDomain) error {
+	if len(domains) == 0 {
+		return nil
+	}
+	byID := make(map[int]*models.PortalDomain, len(domains))
+	placeholders := make([]string, len(domains))
+	args := make([]interface{}, len(domains))
+	for i, d := range domains {
+		d.QueueIDs = make([]int, 0)
+		byID[d.ID] = d
+		placeholders[i] = "?"
+		args[i] = d.ID
+	}
+
+	rows, err := r.db.QueryContext(ctx, database.ConvertPlaceholders(
+		"SELECT domain_id, queue_id FROM customer_portal_domain_queue WHERE domain_id IN ("+
+			strings.Join(placeholders, ",")+") ORDER BY position, queue_id"), args...)
+	if err != nil {
+		return fmt.Errorf("query portal domain queues: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var domainID, queueID int
+		if err := rows.Scan(&domainID, &queueID); err != nil {
+			return fmt.Errorf("scan portal domain queue: %w", err)
+		}
+		byID[domainID].QueueIDs = append(byID[domainID].QueueIDs, queueID)
+	}
+	return rows.Err()
+}
+
+// DomainExists reports whether another portal domain already uses the name.
+func (r *PortalDomainRepository) DomainExists(ctx context.Context, domain string, excludeID int) (bool, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx, database.ConvertPlaceholders(
+		"SELECT COUNT(*) FROM customer_portal_domain WHERE domain = ? AND id <> ?",
+	), domain, excludeID).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("check portal domain: %w", err)
+	}
+	return count > 0, nil
+}
+
+// Create inserts a portal domain with its queues and returns its ID.
+func (r *PortalDomainRepository) Create(ctx context.Context, d *models.PortalDomain, userID int) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("begin portal domain: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	now := time.Now()
+	id, err := database.GetAdapter().InsertWithReturningTx(tx, database.ConvertPlaceholders(`
+		INSERT INTO customer_portal_domain (domain, customer_id, theme, logo_url, sso_provider_id, password_login,
+			cert_status, valid_id, create_time, create_by, change_time, change_by)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id`),
+		d.Domain, d.CustomerID, d.Theme, d.LogoURL, nullablePortalProvider(d.SSOProviderID), boolToSmallint(d.PasswordLogin),
+		string(models.PortalCertPending), d.ValidID, now, userID, now, userID)
+	if err != nil {
+		return 0, fmt.Errorf("insert portal domain: %w", err)
+	}
+	if err := replacePortalDomainQueues(ctx, tx, int(id), d.QueueIDs); err != nil {
+		return 0, err
+	}
+	return int(id), tx.Commit()
+}
+
+// Update stores changes to a portal domain and replaces its queues. The
+// certificate status is reset to pending when the domain name changes.
+func (r *PortalDomainRepository) Update(ctx context.Context, d *models.PortalDomain, userID int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin portal domain: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	result, err := tx.ExecContext(ctx, database.ConvertPlaceholders(`
+		UPDATE customer_portal_domain
+		SET cert_status = CASE WHEN domain = ? THEN cert_status ELSE ? END,
+		    domain = ?, customer_id = ?, theme = ?, logo_url = ?, sso_provider_id = ?, password_login = ?,
+		    valid_id = ?, change_time = ?, change_by = ?
+		WHERE id = ?
+	`), d.Domain, string(models.PortalCertPending),
+		d.Domain, d.CustomerID, d.Theme, d.LogoURL, nullablePortalProvider(d.SSOProviderID), boolToSmallint(d.PasswordLogin),
+		d.ValidID, time.Now(), userID, d.ID)
+	if err != nil {
+		return fmt.Errorf("update portal domain: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	if err := replacePortalDomainQueues(ctx, tx, d.ID, d.QueueIDs); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func replacePortalDomainQueues(ctx context.Context, tx *sql.Tx, domainID int, queueIDs []int) error {
+	if _, err := tx.ExecContext(ctx, database.ConvertPlaceholders(
+		"DELETE FROM customer_portal_domain_queue WHERE domain_id = ?"), domainID); err != nil {
+		return fmt.Errorf("clear portal domain queues: %w", err)
+	}
+	for i, queueID := range queueIDs {
+		if _, err := tx.ExecContext(ctx, database.ConvertPlaceholders(
+			"INSERT INTO customer_portal_domain_queue (domain_id, queue_id, position) VALUES (?, ?, ?)"),
+			domainID, queueID, i); err != nil {
+			return fmt.Errorf("insert portal domain queue %d: %w", queueID, err)
+		}
+	}
+	return nil
+}
+
+// Delete removes a portal domain and its queues.
+func (r *PortalDomainRepository) Delete(ctx context.Context, id int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin portal domain delete: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(ctx, database.ConvertPlaceholders(
+		"DELETE FROM customer_portal_domain_queue WHERE domain_id = ?"), id); err != nil {
+		return fmt.Errorf("clear portal domain queues: %w", err)
+	}
+	result, err := tx.ExecContext(ctx, database.ConvertPlaceholders(
+		"DELETE FROM customer_portal_domain WHERE id = ?"), id)
+	if err != nil {
+		return fmt.Errorf("delete portal domain: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return tx.Commit()
+}
+
+// SetCertificateStatus records the certificate state of a portal domain.
+func (r *PortalDomainRepository) SetCertificateStatus(ctx context.Context, id int, status models.PortalCertStatus, message string) error {
+	result, err := r.db.ExecContext(ctx, database.ConvertPlaceholders(`
+		UPDATE customer_portal_domain SET cert_status = ?, cert_message = ?, cert_change_time = ? WHERE id = ?
+	`), string(status), message, time.Now(), id)
+	if err != nil {
+		return fmt.Errorf("update portal domain certificate: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// CompanyExists reports whether the customer company exists.
+func (r *PortalDomainRepository) CompanyExists(ctx context.Context, customerID string) (bool, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx, database.ConvertPlaceholders(
+		"SELECT COUNT(*) FROM customer_company WHERE customer_id = ?",
+	), customerID).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("check customer company: %w", err)
+	}
+	return count > 0, nil
+}
+
+// QueueExists reports whether a valid queue with the ID exists.
+func (r *PortalDomainRepository) QueueExists(ctx context.Context, queueID int) (bool, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx, database.ConvertPlaceholders(
+		"SELECT COUNT(*) FROM queue WHERE id = ? AND valid_id = 1",
+	), queueID).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("check queue %d: %w", queueID, err)
+	}
+	return count > 0, nil
+}
+
+// CustomerCompany returns the company of a customer user, or "" when the
+// login is unknown.
+func (r *PortalDomainRepository) CustomerCompany(ctx context.Context, login string) (string, error) {
+	var customerID sql.NullString
+	err := r.db.QueryRowContext(ctx, database.ConvertPlaceholders(
+		"SELECT customer_id FROM customer_user WHERE login = ?",
+	), login).Scan(&customerID)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("get customer company: %w", err)
+	}
+	return customerID.String, nil
+}
+
+func nullablePortalProvider(id int) interface{} {
+	if id <= 0 {
+		return nil
+	}
+	return id
+}
+
+func boolToSmallint(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func scanPortalDomain(row kbRowScanner) (*models.PortalDomain, error) {
+	var d models.PortalDomain
+	var passwordLogin int
+	var status string
+	var certTime sql.NullTime
+	if err := row.Scan(&d.ID, &d.Domain, &d.CustomerID, &d.Theme, &d.LogoURL, &d.SSOProviderID,
+		&passwordLogin, &status, &d.CertMessage, &certTime, &d.ValidID,
+		&d.CreateTime, &d.CreateBy, &d.ChangeTime, &d.ChangeBy); err != nil {
+		return nil, err
+	}
+	d.PasswordLogin = passwordLogin == 1
+	d.CertStatus = models.PortalCertStatus(status)
+	if certTime.Valid {
+		t := certTime.Time
+		d.CertChangeTime = &t
+	}
+	return &d, nil
+}
@@ -0,0 +1,297 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/models"
+)
+
+const priorityMatrixLevelSelect = `
+	SELECT id, kind, name, position, valid_id, create_time, create_by, change_time, change_by
+	FROM priority_matrix_level`
+
+// PriorityMatrixRepository handles database operations for the priority
+// matrix: impact and urgency levels, the matrix cells of the default matrix
+// and the queues, and the ratings of tickets.
+type PriorityMatrixRepository struct {
+	db *sql.DB
+}
+
+// NewPriorityMatrixRepository creates a new priority matrix repository.
+func NewPriorityMatrixRepository(db *sql.DB) *PriorityMatrixRepository {
+	return &PriorityMatrixRepository{db: db}
+}
+
+// ListLevels returns the levels of a kind, or of both kinds when kind is
+// empty, ordered by position; validOnly skips invalid levels.
+func (r *PriorityMatrixRepository) ListLevels(ctx context.Context, kind string, validOnly bool) ([]*models.PriorityMatrixLevel, error) {
+	query := priorityMatrixLevelSelect + " WHERE 1 = 1"
+	var args []interface{}
+	if kind != "" {
+		query += " AND kind = ?"
+		args = append(args, kind)
+	}
+	if validOnly {
+		query += " AND valid_id = 1"
+	}
+	rows, err := r.db.QueryContext(ctx, database.ConvertPlaceholders(query+" ORDER BY kind, position, name"), args...)
+	if err != nil {
+		return nil, fmt.Errorf("query priority matrix levels: %w", err)
+	}
+	defer rows.Close()
+
+	levels := []*models.PriorityMatrixLevel{}
+	for rows.Next() {
+		level, err := scanPriorityMatrixLevel(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan priority matrix level: %w", err)
+		}
+		levels = append(levels, level)
+	}
+	return levels, rows.Err()
+}
+
+// GetLevel returns a level, or nil if it does not exist.
+func (r *PriorityMatrixRepository) GetLevel(ctx context.Context, id int) (*models.PriorityMatrixLevel, error) {
+	level, err := scanPriorityMatrixLevel(r.db.QueryRowContext(ctx, database.ConvertPlaceholders(priorityMatrixLevelSelect+" WHERE id = ?"), id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query priority matrix level: %w", err)
+	}
+	return level, nil
+}
+
+// LevelNameExists reports whether another level of the kind already uses
+// the name.
+func (r *PriorityMatrixRepository) LevelNameExists(ctx context.Context, kind, name string, excludeID int) (bool, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx, database.ConvertPlaceholders(
+		"SELECT COUNT(*) FROM priority_matrix_level WHERE kind = ? AND name = ? AND id <> ?"), kind, name, excludeID).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("check priority matrix level name: %w", err)
+	}
+	return count > 0, nil
+}
+
+// CreateLevel inserts a level, setting its ID.
+func (r *PriorityMatrixRepository) CreateLevel(ctx context.Context, level *models.PriorityMatrixLevel) error {
+	id, err := database.GetAdapter().InsertWithReturning(r.db, database.ConvertPlaceholders(`
+		INSERT INTO priority_matrix_level (kind, name, position, valid_id, create_time, create_by, change_time, change_by)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id`),
+		level.Kind, level.Name, level.Position, level.ValidID, level.CreateTime, level.CreateBy, level.ChangeTime, level.ChangeBy)
+	if err != nil {
+		return fmt.Errorf("insert priority matrix level: %w", err)
+	}
+	level.ID = int(id)
+	return nil
+}
+
+// UpdateLevel stores changes to a level's name, position and validity. It
+// returns sql.ErrNoRows when the level does not exist.
+func (r *PriorityMatrixRepository) UpdateLevel(ctx context.Context, level *models.PriorityMatrixLevel) error {
+	result, err := r.db.ExecContext(ctx, database.ConvertPlaceholders(`
+		UPDATE priority_matrix_level
+		SET name = ?, position = ?, valid_id = ?, change_time = ?, change_by = ?
+		WHERE id = ?`),
+		level.Name, level.Position, level.ValidID, level.ChangeTime, level.ChangeBy, level.ID)
+	if err != nil {
+		return fmt.Errorf("update priority matrix level: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// DeleteLevel deletes a level together with its matrix cells, reporting
+// whether it existed.
+func (r *PriorityMatrixRepository) DeleteLevel(ctx context.Context, id int) (bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("begin priority matrix level delete: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(ctx, database.ConvertPlaceholders(
+		"DELETE FROM priority_matrix_cell WHERE impact_id = ? OR urgency_id = ?"), id, id); err != nil {
+		return false, fmt.Errorf("delete priority matrix cells of level: %w", err)
+	}
+	result, err := tx.ExecContext(ctx, database.ConvertPlaceholders("DELETE FROM priority_matrix_level WHERE id = ?"), id)
+	if err != nil {
+		return false, fmt.Errorf("delete priority matrix level: %w", err)
+	}
+	n, _ := result.RowsAffected()
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("commit priority matrix level delete: %w", err)
+	}
+	return n > 0, nil
+}
+
+// CountLevelTickets returns how many tickets are rated with the level.
+func (r *PriorityMatrixRepository) CountLevelTickets(ctx context.Context, id int) (int, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx, database.ConvertPlaceholders(
+		"SELECT COUNT(*) FROM ticket_impact_urgency WHERE impact_id = ? OR urgency_id = ?"), id, id).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("count tickets rated with level: %w", err)
+	}
+	return count, nil
+}
+
+// ListCells returns the cells stored for a queue, or the default matrix
+// when queueID is 0.
+func (r *PriorityMatrixRepository) ListCells(ctx context.Context, queueID int) ([]models.PriorityMatrixCell, error) {
+	rows, err := r.db.QueryContext(ctx, database.ConvertPlaceholders(`
+		SELECT queue_id, impact_id, urgency_id, priority_id
+		FROM priority_matrix_cell
+		WHERE queue_id = ?
+		ORDER BY impact_id, urgency_id`), queueID)
+	if err != nil {
+		return nil, fmt.Errorf("query priority matrix cells: %w", err)
+	}
+	defer rows.Close()
+
+	cells := []models.PriorityMatrixCell{}
+	for rows.Next() {
+		var cell models.PriorityMatrixCell
+		if err := rows.Scan(&cell.QueueID, &cell.ImpactID, &cell.UrgencyID, &cell.PriorityID); err != nil {
+			return nil, fmt.Errorf("scan priority matrix cell: %w", err)
+		}
+		cells = append(cells, cell)
+	}
+	return cells, rows.Err()
+}
+
+// ReplaceCells replaces the cells of a queue, or of the default matrix when
+// queueID is 0.
+func (r *PriorityMatrixRepository) ReplaceCells(ctx context.Context, queueID int, cells []models.PriorityMatrixCell, userID int, now time.Time) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin priority matrix update: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(ctx, database.ConvertPlaceholders(
+		"DELETE FROM priority_matrix_cell WHERE queue_id = ?"), queueID); err != nil {
+		return fmt.Errorf("delete priority matrix cells: %w", err)
+	}
+	insert := database.ConvertPlaceholders(`
+		INSERT INTO priority_matrix_cell (queue_id, impact_id, urgency_id, priority_id, change_time, change_by)
+		VALUES (?, ?, ?, ?, ?, ?)`)
+	for _, cell := range cells {
+		if _, err := tx.ExecContext(ctx, insert, queueID, cell.ImpactID, cell.UrgencyID, cell.PriorityID, now, userID); err != nil {
+			return fmt.Errorf("insert priority matrix cell: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit priority matrix update: %w", err)
+	}
+	return nil
+}
+
+// LookupPriority returns the priority the queue's matrix maps the impact
+// and urgency to, falling back to the default matrix, or 0 when neither
+// has the cell.
+func (r *PriorityMatrixRepository) LookupPriority(ctx context.Context, queueID, impactID, urgencyID int) (int, error) {
+	var priorityID int
+	err := r.db.QueryRowContext(ctx, database.ConvertPlaceholders(`
+		SELECT priority_id
+		FROM priority_matrix_cell
+		WHERE queue_id IN (?, 0) AND impact_id = ? AND urgency_id = ?
+		ORDER BY queue_id DESC
+		LIMIT 1`), queueID, impactID, urgencyID).Scan(&priorityID)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("look up matrix priority: %w", err)
+	}
+	return priorityID, nil
+}
+
+// PriorityExists reports whether the priority exists and is valid.
+func (r *PriorityMatrixRepository) PriorityExists(ctx context.Context, priorityID int) (bool, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx, database.ConvertPlaceholders(
+		"SELECT COUNT(*) FROM ticket_priority WHERE id = ? AND valid_id = 1"), priorityID).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("check ticket priority: %w", err)
+	}
+	return count > 0, nil
+}
+
+// QueueExists reports whether the queue exists.
+func (r *PriorityMatrixRepository) QueueExists(ctx context.Context, queueID int) (bool, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx, database.ConvertPlaceholders(
+		"SELECT COUNT(*) FROM queue WHERE id = ?"), queueID).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("check queue: %w", err)
+	}
+	return count > 0, nil
+}
+
+// GetTicketRating returns the impact and urgency of a ticket, or nil when
+// the ticket is not rated.
+func (r *PriorityMatrixRepository) GetTicketRating(ctx context.Context, ticketID int64) (*models.TicketImpactUrgency, error) {
+	var impactID, urgencyID sql.NullInt64
+	err := r.db.QueryRowContext(ctx, database.ConvertPlaceholders(
+		"SELECT impact_id, urgency_id FROM ticket_impact_urgency WHERE ticket_id = ?"), ticketID).Scan(&impactID, &urgencyID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query ticket impact and urgency: %w", err)
+	}
+	return &models.TicketImpactUrgency{
+		TicketID:  ticketID,
+		ImpactID:  int(impactID.Int64),
+		UrgencyID: int(urgencyID.Int64),
+	}, nil
+}
+
+// SetTicketRating stores the impact and urgency of a ticket; zero IDs are
+// stored as unset.
+func (r *PriorityMatrixRepository) SetTicketRating(ctx context.Context, rating *models.TicketImpactUrgency, userID int, now time.Time) error {
+	impactID, urgencyID := nullLevelID(rating.ImpactID), nullLevelID(rating.UrgencyID)
+	var count int
+	if err := r.db.QueryRowContext(ctx, database.ConvertPlaceholders(
+		"SELECT COUNT(*) FROM ticket_impact_urgency WHERE ticket_id = ?"), rating.TicketID).Scan(&count); err != nil {
+		return fmt.Errorf("check ticket impact and urgency: %w", err)
+	}
+	if count > 0 {
+		if _, err := r.db.ExecContext(ctx, database.ConvertPlaceholders(`
+			UPDATE ticket_impact_urgency
+			SET impact_id = ?, urgency_id = ?, change_time = ?, change_by = ?
+			WHERE ticket_id = ?`), impactID, urgencyID, now, userID, rating.TicketID); err != nil {
+			return fmt.Errorf("update ticket impact and urgency: %w", err)
+		}
+		return nil
+	}
+	if _, err := r.db.ExecContext(ctx, database.ConvertPlaceholders(`
+		INSERT INTO ticket_impact_urgency (ticket_id, impact_id, urgency_id, change_time, change_by)
+		VALUES (?, ?, ?, ?, ?)`), rating.TicketID, impactID, urgencyID, now, userID); err != nil {
+		return fmt.Errorf("insert ticket impact and urgency: %w", err)
+	}
+	return nil
+}
+
+func nullLevelID(id int) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(id), Valid: id > 0}
+}
+
+func scanPriorityMatrixLevel(row kbRowScanner) (*models.PriorityMatrixLevel, error) {
+	var level models.PriorityMatrixLevel
+	if err := row.Scan(&level.ID, &level.Kind, &level.Name, &level.Position, &level.ValidID,
+		&level.CreateTime, &level.CreateBy, &level.ChangeTime, &level.ChangeBy); err != nil {
+		return nil, err
+	}
+	return &level, nil
+}
@@ -0,0 +1,136 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/testutil"
+)
+
+func TestPriorityMatrixRepository(t *testing.T) {
+	db := testutil.UseMigratedDB(t)
+	_, err := db.Exec(`INSERT INTO users (id, login, pw, first_name, last_name, valid_id, create_time, create_by, change_time, change_by)
+		VALUES (1, 'root@localhost', 'x', 'Admin', 'OTRS', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+	insertArchiveTestTicket(t, db, 1, 1, now)
+	repo := NewPriorityMatrixRepository(db)
+
+	level := func(kind, name string, position, validID int) *models.PriorityMatrixLevel {
+		l := &models.PriorityMatrixLevel{Kind: kind, Name: name, Position: position, ValidID: validID,
+			CreateTime: now, CreateBy: 1, ChangeTime: now, ChangeBy: 1}
+		require.NoError(t, repo.CreateLevel(ctx, l))
+		require.NotZero(t, l.ID)
+		return l
+	}
+	high := level(models.PriorityMatrixImpact, "High", 1, 1)
+	low := level(models.PriorityMatrixImpact, "Low", 2, 1)
+	urgent := level(models.PriorityMatrixUrgency, "Urgent", 1, 1)
+	later := level(models.PriorityMatrixUrgency, "Later", 2, 2)
+
+	exists, err := repo.LevelNameExists(ctx, models.PriorityMatrixImpact, "High", 0)
+	require.NoError(t, err)
+	assert.True(t, exists)
+	exists, err = repo.LevelNameExists(ctx, models.PriorityMatrixUrgency, "High", 0)
+	require.NoError(t, err)
+	assert.False(t, exists, "names are unique per kind")
+
+	impacts, err := repo.ListLevels(ctx, models.PriorityMatrixImpact, true)
+	require.NoError(t, err)
+	require.Len(t, impacts, 2)
+	assert.Equal(t, []string{"High", "Low"}, []string{impacts[0].Name, impacts[1].Name})
+	all, err := repo.ListLevels(ctx, "", true)
+	require.NoError(t, err)
+	assert.Len(t, all, 3, "invalid levels are skipped")
+
+	later.Name, later.ValidID, later.ChangeTime = "Whenever", 1, now.Add(time.Minute)
+	require.NoError(t, repo.UpdateLevel(ctx, later))
+	loaded, err := repo.GetLevel(ctx, later.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Whenever", loaded.Name)
+	assert.Equal(t, models.PriorityMatrixUrgency, loaded.Kind)
+	missing, err := repo.GetLevel(ctx, 9999)
+	require.NoError(t, err)
+	assert.Nil(t, missing)
+	assert.Error(t, repo.UpdateLevel(ctx, &models.PriorityMatrixLevel{ID: 9999, Name: "x", ValidID: 1}))
+
+	require.NoError(t, repo.ReplaceCells(ctx, 0, []models.PriorityMatrixCell{
+		{ImpactID: high.ID, UrgencyID: urgent.ID, PriorityID: 5},
+		{ImpactID: high.ID, UrgencyID: later.ID, PriorityID: 4},
+		{ImpactID: low.ID, UrgencyID: urgent.ID, PriorityID: 3},
+	}, 1, now))
+	require.NoError(t, repo.ReplaceCells(ctx, 2, []models.PriorityMatrixCell{
+		{ImpactID: high.ID, UrgencyID: later.ID, PriorityID: 5},
+	}, 1, now))
+
+	cells, err := repo.ListCells(ctx, 0)
+	require.NoError(t, err)
+	assert.Len(t, cells, 3)
+	cells, err = repo.ListCells(ctx, 2)
+	require.NoError(t, err)
+	require.Len(t, cells, 1)
+	assert.Equal(t, 2, cells[0].QueueID)
+
+	for _, tc := range []struct {
+		queueID, impactID, urgencyID, want int
+	}{
+		{1, high.ID, later.ID, 4},
+		{2, high.ID, later.ID, 5}, // queue override
+		{2, high.ID, urgent.ID, 5},
+		{2, low.ID, urgent.ID, 3}, // default cell
+		{1, low.ID, later.ID, 0},  // no cell
+	} {
+		got, err := repo.LookupPriority(ctx, tc.queueID, tc.impactID, tc.urgencyID)
+		require.NoError(t, err)
+		assert.Equal(t, tc.want, got, "queue %d impact %d urgency %d", tc.queueID, tc.impactID, tc.urgencyID)
+	}
+
+	require.NoError(t, repo.ReplaceCells(ctx, 2, nil, 1, now))
+	cells, err = repo.ListCells(ctx, 2)
+	require.NoError(t, err)
+	assert.Empty(t, cells)
+
+	ok, err := repo.PriorityExists(ctx, 3)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = repo.PriorityExists(ctx, 99)
+	require.NoError(t, err)
+	assert.False(t, ok)
+	ok, err = repo.QueueExists(ctx, 1)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	rating, err := repo.GetTicketRating(ctx, 1)
+	require.NoError(t, err)
+	assert.Nil(t, rating)
+	require.NoError(t, repo.SetTicketRating(ctx, &models.TicketImpactUrgency{TicketID: 1, ImpactID: high.ID}, 1, now))
+	rating, err = repo.GetTicketRating(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, &models.TicketImpactUrgency{TicketID: 1, ImpactID: high.ID}, rating)
+	require.NoError(t, repo.SetTicketRating(ctx, &models.TicketImpactUrgency{TicketID: 1, ImpactID: low.ID, UrgencyID: urgent.ID}, 1, now))
+	rating, err = repo.GetTicketRating(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, low.ID, rating.ImpactID)
+	assert.Equal(t, urgent.ID, rating.UrgencyID)
+
+	n, err := repo.CountLevelTickets(ctx, urgent.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	deleted, err := repo.DeleteLevel(ctx, high.ID)
+	require.NoError(t, err)
+	assert.True(t, deleted)
+	cells, err = repo.ListCells(ctx, 0)
+	require.NoError(t, err)
+	assert.Len(t, cells, 1, "cells of deleted levels go with them")
+	deleted, err = repo.DeleteLevel(ctx, high.ID)
+	require.NoError(t, err)
+	assert.False(t, deleted)
+}
@@ -0,0 +1,624 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/models"
+)
+
+const privacyRequestSelect = `
+	SELECT id, kind, subject, customer_login, COALESCE(field_policy, ''), COALESCE(reason, ''), status,
+	       requested_by, requested_at, decided_by, decided_at, COALESCE(decision_comment, ''),
+	       COALESCE(job_id, ''), started_at, finished_at, COALESCE(result, ''), COALESCE(error_message, '')
+	FROM privacy_request`
+
+// privacyArticles restricts a statement on an article content table to the
+// articles of one customer user's tickets. It takes the login.
+const privacyArticles = `article_id IN (
+	SELECT a.id FROM article a JOIN ticket t ON t.id = a.ticket_id WHERE t.customer_user_id = ?)`
+
+// privacyContentTables hold article content, live and archived.
+var privacyContentTables = []string{"article_data_mime", "article_data_mime_archive"}
+
+// ErrPrivacyRequestNotPending is returned when a privacy request is no
+// longer waiting for a decision.
+var ErrPrivacyRequestNotPending = errors.New("privacy request is no longer pending")
+
+// PrivacyRepository handles data subject requests and reads and changes
+// the personal data they cover.
+type PrivacyRepository struct {
+	db *sql.DB
+}
+
+// NewPrivacyRepository creates a new privacy repository.
+func NewPrivacyRepository(db *sql.DB) *PrivacyRepository {
+	return &PrivacyRepository{db: db}
+}
+
+// CreateRequest stores a new request and returns its ID.
+func (r *PrivacyRepository) CreateRequest(ctx context.Context, req *models.PrivacyRequest) (int, error) {
+	var policy interface{}
+	if len(req.FieldPolicy) > 0 {
+		data, err := json.Marshal(req.FieldPolicy)
+		if err != nil {
+			return 0, fmt.Errorf("encode field policy: %w", err)
+		}
+		policy = string(data)
+	}
+	id, err := database.GetAdapter().InsertWithReturning(r.db, database.ConvertPlaceholders(`
+		INSERT INTO privacy_request (kind, subject, customer_login, field_policy, reason, status, requested_by, requested_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id`),
+		string(req.Kind), req.Subject, req.CustomerLogin, policy, req.Reason, string(req.Status), req.RequestedBy, req.RequestedAt)
+	if err != nil {
+		return 0, fmt.Errorf("insert privacy request: %w", err)
+	}
+	return int(id), nil
+}
+
+// GetRequest returns a request by ID, or nil if it does not exist.
+func (r *PrivacyRepository) GetRequest(ctx context.Context, id int) (*models.PrivacyRequest, error) {
+	row := r.db.QueryRowContext(ctx, database.ConvertPlaceholders(privacyRequestSelect+" WHERE id = ?"), id)
+	req, err := scanPrivacyRequest(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get privacy request: %w", err)
+	}
+	return req, nil
+}
+
+// ListRequests returns the most recent requests, newest first, optionally
+// only those with status.
+func (r *PrivacyRepository) ListRequests(ctx context.Context, status models.PrivacyRequestStatus, limit int) ([]*models.PrivacyRequest, error) {
+	query := privacyRequestSelect
+	var args []interface{}
+	if status != "" {
+		query += " WHERE status = ?"
+		args = append(args, string(status))
+	}
+	query += " ORDER BY requested_at DESC, id DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := r.db.QueryContext(ctx, database.ConvertPlaceholders(query), args...)
+	if err != nil {
+		return nil, fmt.Errorf("query privacy requests: %w", err)
+	}
+	defer rows.Close()
+
+	var requests []*models.PrivacyRequest
+	for rows.Next() {
+		req, err := scanPrivacyRequest(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan privacy request: %w", err)
+		}
+		requests = append(requests, req)
+	}
+	return requests, rows.Err()
+}
+
+// Decide records the decision on a pending request.
+// ErrPrivacyRequestNotPending is returned when it is not pending.
+func (r *PrivacyRepository) Decide(ctx context.Context, id int, status models.PrivacyRequestStatus, userID int, comment string, now time.Time) error {
+	result, err := r.db.ExecContext(ctx, database.ConvertPlaceholders(`
+		UPDATE privacy_request
+		SET status = ?, decided_by = ?, decided_at = ?, decision_comment = ?
+		WHERE id = ? AND status = ?
+	`), string(status), userID, now, comment, id, string(models.PrivacyStatusPending))
+	if err != nil {
+		return fmt.Errorf("update privacy request: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrPrivacyRequestNotPending
+	}
+	return nil
+}
+
+// SetJobID records the background job running the request.
+func (r *PrivacyRepository) SetJobID(ctx context.Context, id int, jobID string) error {
+	_, err := r.db.ExecContext(ctx, database.ConvertPlaceholders(
+		"UPDATE privacy_request SET job_id = ? WHERE id = ?"), jobID, id)
+	if err != nil {
+		return fmt.Errorf("update privacy request job: %w", err)
+	}
+	return nil
+}
+
+// StartRequest marks the request as running.
+func (r *PrivacyRepository) StartRequest(ctx context.Context, id int, now time.Time) error {
+	_, err := r.db.ExecContext(ctx, database.ConvertPlaceholders(`
+		UPDATE privacy_request SET status = ?, started_at = ?, finished_at = NULL, error_message = NULL
+		WHERE id = ?
+	`), string(models.PrivacyStatusRunning), now, id)
+	if err != nil {
+		return fmt.Errorf("start privacy request: %w", err)
+	}
+	return nil
+}
+
+// FinishRequest stores the outcome of a request.
+func (r *PrivacyRepository) FinishRequest(ctx context.Context, id int, status models.PrivacyRequestStatus, result map[string]int, errMsg string, now time.Time) error {
+	var resultJSON, errValue interface{}
+	if len(result) > 0 {
+		data, err := json.Marshal(result)
+		if err != nil {
+			return fmt.Errorf("encode privacy result: %w", err)
+		}
+		resultJSON = string(data)
+	}
+	if errMsg != "" {
+		if len(errMsg) > 1000 {
+			errMsg = errMsg[:1000]
+		}
+		errValue = errMsg
+	}
+	_, err := r.db.ExecContext(ctx, database.ConvertPlaceholders(`
+		UPDATE privacy_request SET status = ?, result = ?, error_message = ?, finished_at = ?
+		WHERE id = ?
+	`), string(status), resultJSON, errValue, now, id)
+	if err != nil {
+		return fmt.Errorf("finish privacy request: %w", err)
+	}
+	return nil
+}
+
+// AddLog adds an entry to the request's audit trail.
+func (r *PrivacyRepository) AddLog(ctx context.Context, requestID int, action, message string, userID int) error {
+	if len(message) > 1000 {
+		message = message[:1000]
+	}
+	_, err := r.db.ExecContext(ctx, database.ConvertPlaceholders(`
+		INSERT INTO privacy_request_log (request_id, action, message, create_time, create_by)
+		VALUES (?, ?, ?, ?, ?)
+	`), requestID, action, message, time.Now(), userID)
+	if err != nil {
+		return fmt.Errorf("insert privacy request log: %w", err)
+	}
+	return nil
+}
+
+// ListLogs returns the audit trail of a request, oldest first.
+func (r *PrivacyRepository) ListLogs(ctx context.Context, requestID int) ([]*models.PrivacyRequestLog, error) {
+	rows, err := r.db.QueryContext(ctx, database.ConvertPlaceholders(`
+		SELECT id, request_id, action, COALESCE(message, ''), create_time, create_by
+		FROM privacy_request_log WHERE request_id = ? ORDER BY id
+	`), requestID)
+	if err != nil {
+		return nil, fmt.Errorf("query privacy request log: %w", err)
+	}
+	defer rows.Close()
+
+	var logs []*models.PrivacyRequestLog
+	for rows.Next() {
+		var l models.PrivacyRequestLog
+		if err := rows.Scan(&l.ID, &l.RequestID, &l.Action, &l.Message, &l.CreateTime, &l.CreateBy); err != nil {
+			return nil, fmt.Errorf("scan privacy request log: %w", err)
+		}
+		logs = append(logs, &l)
+	}
+	return logs, rows.Err()
+}
+
+// SaveExport stores the ZIP of an export, replacing an earlier one.
+func (r *PrivacyRepository) SaveExport(ctx context.Context, requestID int, filename string, content []byte) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin privacy export: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(ctx, database.ConvertPlaceholders(
+		"DELETE FROM privacy_export WHERE request_id = ?"), requestID); err != nil {
+		return fmt.Errorf("delete privacy export: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, database.ConvertPlaceholders(`
+		INSERT INTO privacy_export (request_id, filename, content_size, content, create_time)
+		VALUES (?, ?, ?, ?, ?)
+	`), requestID, filename, len(content), content, time.Now()); err != nil {
+		return fmt.Errorf("insert privacy export: %w", err)
+	}
+	return tx.Commit()
+}
+
+// GetExport returns the ZIP of an export. sql.ErrNoRows is returned when
+// there is none.
+func (r *PrivacyRepository) GetExport(ctx context.Context, requestID int) (string, []byte, error) {
+	var filename string
+	var content []byte
+	err := r.db.QueryRowContext(ctx, database.ConvertPlaceholders(
+		"SELECT filename, content FROM privacy_export WHERE request_id = ?"), requestID).Scan(&filename, &content)
+	if err != nil {
+		return "", nil, err
+	}
+	return filename, content, nil
+}
+
+// FindCustomerLogin resolves a login or email address to the login of a
+// customer user. Logins of tickets from unregistered customers are found
+// too. It returns "" when nothing matches.
+func (r *PrivacyRepository) FindCustomerLogin(ctx context.Context, subject string) (string, error) {
+	for _, query := range []string{
+		"SELECT login FROM customer_user WHERE login = ?",
+		"SELECT login FROM customer_user WHERE LOWER(email) = LOWER(?) ORDER BY id",
+		"SELECT customer_user_id FROM ticket WHERE customer_user_id = ? ORDER BY id",
+	} {
+		var login string
+		err := r.db.QueryRowContext(ctx, database.ConvertPlaceholders(query+" LIMIT 1"), subject).Scan(&login)
+		if err == nil {
+			return login, nil
+		}
+		if err != sql.ErrNoRows {
+			return "", fmt.Errorf("find customer login: %w", err)
+		}
+	}
+	return "", nil
+}
+
+// CustomerData returns the customer user record, without the password, and
+// its preferences. The record is nil for logins without a customer user.
+func (r *PrivacyRepository) CustomerData(ctx context.Context, login string) (map[string]interface{}, []map[string]interface{}, error) {
+	users, err := r.queryMaps(ctx, "SELECT * FROM customer_user WHERE login = ?", login)
+	if err != nil {
+		return nil, nil, fmt.Errorf("query customer user: %w", err)
+	}
+	var user map[string]interface{}
+	if len(users) > 0 {
+		user = users[0]
+		delete(user, "pw")
+	}
+	prefs, err := r.queryMaps(ctx, `
+		SELECT preferences_key, preferences_value FROM customer_preferences
+		WHERE user_id = ? ORDER BY preferences_key`, login)
+	if err != nil {
+		return nil, nil, fmt.Errorf("query customer preferences: %w", err)
+	}
+	return user, prefs, nil
+}
+
+// TicketData returns the customer user's tickets, their articles with
+// live or archived content, and their history.
+func (r *PrivacyRepository) TicketData(ctx context.Context, login string) (tickets, articles, history []map[string]interface{}, err error) {
+	tickets, err = r.queryMaps(ctx, "SELECT * FROM ticket WHERE customer_user_id = ? ORDER BY id", login)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("query tickets: %w", err)
+	}
+	articles, err = r.queryMaps(ctx, `
+		SELECT a.id AS article_id, a.ticket_id, st.name AS sender_type, a.is_visible_for_customer,
+		       COALESCE(m.a_from, ma.a_from) AS a_from, COALESCE(m.a_to, ma.a_to) AS a_to,
+		       COALESCE(m.a_cc, ma.a_cc) AS a_cc, COALESCE(m.a_reply_to, ma.a_reply_to) AS a_reply_to,
+		       COALESCE(m.a_subject, ma.a_subject) AS a_subject, COALESCE(m.a_body, ma.a_body) AS a_body,
+		       COALESCE(m.a_content_type, ma.a_content_type) AS a_content_type, a.create_time
+		FROM article a
+		JOIN ticket t ON t.id = a.ticket_id
+		LEFT JOIN article_sender_type st ON st.id = a.article_sender_type_id
+		LEFT JOIN article_data_mime m ON m.article_id = a.id
+		LEFT JOIN article_data_mime_archive ma ON ma.article_id = a.id
+		WHERE t.customer_user_id = ?
+		ORDER BY a.ticket_id, a.id`, login)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("query articles: %w", err)
+	}
+	history, err = r.queryMaps(ctx, `
+		SELECT h.ticket_id, h.name, ht.name AS history_type, h.create_time, h.create_by
+		FROM ticket_history h
+		JOIN ticket t ON t.id = h.ticket_id
+		LEFT JOIN ticket_history_type ht ON ht.id = h.history_type_id
+		WHERE t.customer_user_id = ?
+		ORDER BY h.ticket_id, h.id`, login)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("query ticket history: %w", err)
+	}
+	return tickets, articles, history, nil
+}
+
+// EachAttachment calls fn with every attachment of the customer user's
+// tickets stored in the database, live or archived.
+func (r *PrivacyRepository) EachAttachment(ctx context.Context, login string,
+	fn func(ticketNumber string, articleID int64, filename string, content []byte) error) error {
+	for _, table := range []string{"article_data_mime_attachment", "article_data_mime_attachment_archive"} {
+		rows, err := r.db.QueryContext(ctx, database.ConvertPlaceholders(`
+			SELECT t.tn, att.article_id, COALESCE(att.filename, ''), att.content
+			FROM `+table+` att
+			JOIN article a ON a.id = att.article_id
+			JOIN ticket t ON t.id = a.ticket_id
+			WHERE t.customer_user_id = ? AND att.content IS NOT NULL
+			ORDER BY att.article_id, att.id`), login)
+		if err != nil {
+			return fmt.Errorf("query attachments: %w", err)
+		}
+		for rows.Next() {
+			var tn, filename string
+			var articleID int64
+			var content []byte
+			if err := rows.Scan(&tn, &articleID, &filename, &content); err != nil {
+				rows.Close()
+				return fmt.Errorf("scan attachment: %w", err)
+			}
+			if err := fn(tn, articleID, filename, content); err != nil {
+				rows.Close()
+				return err
+			}
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// PrivacyErasure is the set of changes an erasure makes to one customer
+// user's data.
+type PrivacyErasure struct {
+	Login string
+	// NewLogin replaces the login on the customer user, its tickets and
+	// preferences when set.
+	NewLogin string
+	// Customer maps customer_user columns to their new values.
+	Customer          map[string]interface{}
+	DeletePreferences bool
+	TicketTitle       *string
+	ArticleSubject    *string
+	ClearArticleBody  bool
+	DeleteAttachments bool
+	// AddressReplacements are old, new pairs replaced, ignoring case, in
+	// the address headers of the articles.
+	AddressReplacements []string
+}
+
+// changesArticles reports whether the erasure changes article content.
+func (e *PrivacyErasure) changesArticles() bool {
+	return e.ArticleSubject != nil || e.ClearArticleBody || e.DeleteAttachments || len(e.AddressReplacements) > 0
+}
+
+// Erase applies an erasure in one transaction and returns counts of what
+// it changed. Raw emails (article_data_mime_plain) and search index entries
+// of the tickets are deleted whenever article content changes.
+func (r *PrivacyRepository) Erase(ctx context.Context, e *PrivacyErasure, userID int, now time.Time) (map[string]int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin erasure: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	counts := map[string]int{}
+	exec := func(key, query string, args ...interface{}) error {
+		result, err := tx.ExecContext(ctx, database.ConvertPlaceholders(query), args...)
+		if err != nil {
+			return err
+		}
+		if key != "" {
+			n, _ := result.RowsAffected()
+			counts[key] += int(n)
+		}
+		return nil
+	}
+
+	if len(e.Customer) > 0 {
+		columns := make([]string, 0, len(e.Customer))
+		for column := range e.Customer {
+			columns = append(columns, column)
+		}
+		sort.Strings(columns)
+		sets := make([]string, 0, len(columns)+2)
+		args := make([]interface{}, 0, len(columns)+3)
+		for _, column := range columns {
+			sets = append(sets, column+" = ?")
+			args = append(args, e.Customer[column])
+		}
+		sets = append(sets, "change_time = ?", "change_by = ?")
+		args = append(args, now, userID, e.Login)
+		if err := exec("customer_users", "UPDATE customer_user SET "+strings.Join(sets, ", ")+" WHERE login = ?", args...); err != nil {
+			return nil, fmt.Errorf("erase customer user: %w", err)
+		}
+	}
+	if e.DeletePreferences {
+		if err := exec("preferences", "DELETE FROM customer_preferences WHERE user_id = ?", e.Login); err != nil {
+			return nil, fmt.Errorf("erase customer preferences: %w", err)
+		}
+	}
+	if e.TicketTitle != nil {
+		if err := exec("tickets", `UPDATE ticket SET title = ?, change_time = ?, change_by = ?
+			WHERE customer_user_id = ?`, *e.TicketTitle, now, userID, e.Login); err != nil {
+			return nil, fmt.Errorf("erase ticket titles: %w", err)
+		}
+	}
+
+	for _, table := range privacyContentTables {
+		if e.ArticleSubject != nil {
+			if err := exec("articles", "UPDATE "+table+" SET a_subject = ? WHERE "+privacyArticles,
+				*e.ArticleSubject, e.Login); err != nil {
+				return nil, fmt.Errorf("erase article subjects: %w", err)
+			}
+		}
+		if e.ClearArticleBody {
+			if err := exec("articles", "UPDATE "+table+" SET a_body = '' WHERE "+privacyArticles, e.Login); err != nil {
+				return nil, fmt.Errorf("erase article bodies: %w", err)
+			}
+		}
+		if len(e.AddressReplacements) > 0 {
+			n, err := replaceArticleAddresses(ctx, tx, table, e.Login, e.AddressReplacements)
+			if err != nil {
+				return nil, err
+			}
+			counts["articles"] += n
+		}
+	}
+	if e.DeleteAttachments {
+		for _, table := range []string{"article_data_mime_attachment", "article_data_mime_attachment_archive"} {
+			if err := exec("attachments", "DELETE FROM "+table+" WHERE "+privacyArticles, e.Login); err != nil {
+				return nil, fmt.Errorf("erase attachments: %w", err)
+			}
+		}
+	}
+	if e.changesArticles() {
+		for _, stmt := range []string{
+			"DELETE FROM article_data_mime_plain WHERE " + privacyArticles,
+			"DELETE FROM article_data_mime_plain_archive WHERE " + privacyArticles,
+			"DELETE FROM article_visible_text WHERE " + privacyArticles,
+			"DELETE FROM article_search_index WHERE ticket_id IN (SELECT id FROM ticket WHERE customer_user_id = ?)",
+		} {
+			if err := exec("", stmt, e.Login); err != nil {
+				return nil, fmt.Errorf("erase raw articles: %w", err)
+			}
+		}
+	}
+
+	if e.NewLogin != "" && e.NewLogin != e.Login {
+		for _, stmt := range []string{
+			"UPDATE ticket SET customer_user_id = ? WHERE customer_user_id = ?",
+			"UPDATE customer_preferences SET user_id = ? WHERE user_id = ?",
+			"UPDATE customer_user_customer SET user_id = ? WHERE user_id = ?",
+			"UPDATE customer_user SET login = ? WHERE login = ?",
+		} {
+			if err := exec("", stmt, e.NewLogin, e.Login); err != nil {
+				return nil, fmt.Errorf("replace customer login: %w", err)
+			}
+		}
+	}
+	return counts, tx.Commit()
+}
+
+// replaceArticleAddresses applies replacements to the address headers of
+// the customer user's articles in table and returns the number of articles
+// changed.
+func replaceArticleAddresses(ctx context.Context, tx *sql.Tx, table, login string, replacements []string) (int, error) {
+	patterns := make([]*regexp.Regexp, 0, len(replacements)/2)
+	for i := 0; i+1 < len(replacements); i += 2 {
+		if replacements[i] == "" {
+			patterns = append(patterns, nil)
+			continue
+		}
+		patterns = append(patterns, regexp.MustCompile("(?i)"+regexp.QuoteMeta(replacements[i])))
+	}
+
+	rows, err := tx.QueryContext(ctx, database.ConvertPlaceholders(`
+		SELECT id, COALESCE(a_from, ''), COALESCE(a_to, ''), COALESCE(a_cc, ''), COALESCE(a_reply_to, '')
+		FROM `+table+` WHERE `+privacyArticles), login)
+	if err != nil {
+		return 0, fmt.Errorf("query article addresses: %w", err)
+	}
+	type addresses struct {
+		id     int64
+		fields [4]string
+	}
+	var changed []addresses
+	for rows.Next() {
+		var a addresses
+		if err := rows.Scan(&a.id, &a.fields[0], &a.fields[1], &a.fields[2], &a.fields[3]); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("scan article addresses: %w", err)
+		}
+		dirty := false
+		for i, value := range a.fields {
+			for j, re := range patterns {
+				if re != nil {
+					value = re.ReplaceAllLiteralString(value, replacements[2*j+1])
+				}
+			}
+			if value != a.fields[i] {
+				a.fields[i] = value
+				dirty = true
+			}
+		}
+		if dirty {
+			changed = append(changed, a)
+		}
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return 0, err
+	}
+
+	for _, a := range changed {
+		if _, err := tx.ExecContext(ctx, database.ConvertPlaceholders(`
+			UPDATE `+table+` SET a_from = ?, a_to = ?, a_cc = ?, a_reply_to = ? WHERE id = ?
+		`), a.fields[0], a.fields[1], a.fields[2], a.fields[3], a.id); err != nil {
+			return 0, fmt.Errorf("update article addresses: %w", err)
+		}
+	}
+	return len(changed), nil
+}
+
+// queryMaps returns the rows of a query as column name => value maps, with
+// byte slices turned into strings.
+func (r *PrivacyRepository) queryMaps(ctx context.Context, query string, args ...interface{}) ([]map[string]interface{}, error) {
+	rows, err := r.db.QueryContext(ctx, database.ConvertPlaceholders(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	result := []map[string]interface{}{}
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		ptrs := make([]interface{}, len(columns))
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, err
+		}
+		m := make(map[string]interface{}, len(columns))
+		for i, column := range columns {
+			if b, ok := values[i].([]byte); ok {
+				m[column] = string(b)
+			} else {
+				m[column] = values[i]
+			}
+		}
+		result = append(result, m)
+	}
+	return result, rows.Err()
+}
+
+func scanPrivacyRequest(row kbRowScanner) (*models.PrivacyRequest, error) {
+	var req models.PrivacyRequest
+	var kind, status, policy, result string
+	var decidedBy sql.NullInt64
+	var decidedAt, startedAt, finishedAt sql.NullTime
+	if err := row.Scan(&req.ID, &kind, &req.Subject, &req.CustomerLogin, &policy, &req.Reason, &status,
+		&req.RequestedBy, &req.RequestedAt, &decidedBy, &decidedAt, &req.DecisionComment,
+		&req.JobID, &startedAt, &finishedAt, &result, &req.Error); err != nil {
+		return nil, err
+	}
+	req.Kind = models.PrivacyRequestKind(kind)
+	req.Status = models.PrivacyRequestStatus(status)
+	req.DecidedBy = intPtrFromNull(decidedBy)
+	if decidedAt.Valid {
+		req.DecidedAt = &decidedAt.Time
+	}
+	if startedAt.Valid {
+		req.StartedAt = &startedAt.Time
+	}
+	if finishedAt.Valid {
+		req.FinishedAt = &finishedAt.Time
+	}
+	if policy != "" {
+		if err := json.Unmarshal([]byte(policy), &req.FieldPolicy); err != nil {
+			return nil, fmt.Errorf("decode field policy: %w", err)
+		}
+	}
+	if result != "" {
+		if err := json.Unmarshal([]byte(result), &req.Result); err != nil {
+			return nil, fmt.Errorf("decode privacy result: %w", err)
+		}
+	}
+	return &req, nil
+}
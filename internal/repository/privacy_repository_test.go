@@ -0,0 +1,129 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/testutil"
+)
+
+func TestPrivacyRepository_Requests(t *testing.T) {
+	db := testutil.UseMigratedDB(t)
+	_, err := db.Exec(`INSERT INTO users (id, login, pw, first_name, last_name, valid_id, create_time, create_by, change_time, change_by)
+		VALUES (1, 'root@localhost', 'x', 'Admin', 'OTRS', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`)
+	require.NoError(t, err)
+
+	repo := NewPrivacyRepository(db)
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+
+	id, err := repo.CreateRequest(ctx, &models.PrivacyRequest{
+		Kind: models.PrivacyRequestErasure, Subject: "jane@example.com", CustomerLogin: "jane",
+		FieldPolicy: map[string]models.PrivacyFieldAction{"customer.name": models.PrivacyErase},
+		Status:      models.PrivacyStatusPending, RequestedBy: 1, RequestedAt: now,
+	})
+	require.NoError(t, err)
+
+	req, err := repo.GetRequest(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, models.PrivacyRequestErasure, req.Kind)
+	assert.Equal(t, models.PrivacyErase, req.FieldPolicy["customer.name"])
+	assert.Nil(t, req.DecidedBy)
+
+	require.NoError(t, repo.Decide(ctx, id, models.PrivacyStatusApproved, 1, "ok", now))
+	assert.ErrorIs(t, repo.Decide(ctx, id, models.PrivacyStatusRejected, 1, "", now), ErrPrivacyRequestNotPending)
+
+	require.NoError(t, repo.StartRequest(ctx, id, now))
+	require.NoError(t, repo.FinishRequest(ctx, id, models.PrivacyStatusCompleted, map[string]int{"tickets": 2}, "", now))
+	require.NoError(t, repo.AddLog(ctx, id, "completed", "", 1))
+
+	req, err = repo.GetRequest(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, models.PrivacyStatusCompleted, req.Status)
+	assert.Equal(t, map[string]int{"tickets": 2}, req.Result)
+	require.NotNil(t, req.DecidedBy)
+	assert.Equal(t, 1, *req.DecidedBy)
+
+	logs, err := repo.ListLogs(ctx, id)
+	require.NoError(t, err)
+	require.Len(t, logs, 1)
+	assert.Equal(t, "completed", logs[0].Action)
+
+	require.NoError(t, repo.SaveExport(ctx, id, "export.zip", []byte("PK")))
+	name, content, err := repo.GetExport(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, "export.zip", name)
+	assert.Equal(t, []byte("PK"), content)
+
+	missing, err := repo.GetRequest(ctx, id+1)
+	require.NoError(t, err)
+	assert.Nil(t, missing)
+}
+
+func TestPrivacyRepository_Erase(t *testing.T) {
+	db := testutil.UseMigratedDB(t)
+	_, err := db.Exec(`INSERT INTO users (id, login, pw, first_name, last_name, valid_id, create_time, create_by, change_time, change_by)
+		VALUES (1, 'root@localhost', 'x', 'Admin', 'OTRS', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`)
+	require.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO customer_user (login, email, customer_id, first_name, last_name, phone, valid_id,
+		create_time, create_by, change_time, change_by)
+		VALUES ('jane', 'Jane@Example.com', 'acme', 'Jane', 'Doe', '555-1234', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`)
+	require.NoError(t, err)
+	insertArchiveTestTicket(t, db, 1, 4, time.Now())
+	_, err = db.Exec(`UPDATE ticket SET customer_user_id = 'jane' WHERE id = 1`)
+	require.NoError(t, err)
+	_, err = db.Exec(`UPDATE article_data_mime SET a_from = 'Jane Doe <jane@example.com>' WHERE article_id = 10`)
+	require.NoError(t, err)
+
+	repo := NewPrivacyRepository(db)
+	ctx := context.Background()
+
+	login, err := repo.FindCustomerLogin(ctx, "jane@EXAMPLE.com")
+	require.NoError(t, err)
+	assert.Equal(t, "jane", login)
+	login, err = repo.FindCustomerLogin(ctx, "nobody")
+	require.NoError(t, err)
+	assert.Empty(t, login)
+
+	user, _, err := repo.CustomerData(ctx, "jane")
+	require.NoError(t, err)
+	assert.Equal(t, "Jane", user["first_name"])
+	assert.NotContains(t, user, "pw")
+	tickets, articles, _, err := repo.TicketData(ctx, "jane")
+	require.NoError(t, err)
+	assert.Len(t, tickets, 1)
+	require.Len(t, articles, 1)
+	assert.Equal(t, "It is on fire", articles[0]["a_body"])
+
+	var attachments []string
+	require.NoError(t, repo.EachAttachment(ctx, "jane", func(tn string, articleID int64, filename string, content []byte) error {
+		attachments = append(attachments, filename)
+		return nil
+	}))
+	assert.Equal(t, []string{"smoke.jpg"}, attachments)
+
+	counts, err := repo.Erase(ctx, &PrivacyErasure{
+		Login:               "jane",
+		NewLogin:            "anon-1",
+		Customer:            map[string]interface{}{"first_name": "anon", "last_name": "anon", "phone": nil},
+		ClearArticleBody:    true,
+		DeleteAttachments:   true,
+		AddressReplacements: []string{"jane@example.com", "anon-1@invalid", "Jane Doe", "anon"},
+	}, 1, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, 1, counts["customer_users"])
+	assert.Equal(t, 1, counts["attachments"])
+
+	assert.Equal(t, 1, countRows(t, db, "SELECT COUNT(*) FROM ticket WHERE customer_user_id = 'anon-1'"))
+	assert.Equal(t, 1, countRows(t, db, "SELECT COUNT(*) FROM customer_user WHERE login = 'anon-1' AND phone IS NULL"))
+	assert.Zero(t, countRows(t, db, "SELECT COUNT(*) FROM article_data_mime_attachment"))
+	var from, body string
+	require.NoError(t, db.QueryRow("SELECT a_from, a_body FROM article_data_mime WHERE article_id = 10").Scan(&from, &body))
+	assert.Equal(t, "anon <anon-1@invalid>", from)
+	assert.Empty(t, body)
+}
@@ -0,0 +1,145 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/models"
+)
+
+// QueueReference names a lookup table a queue points to.
+type QueueReference string
+
+// Lookup tables referenced by queue columns.
+const (
+	QueueRefGroup         QueueReference = "groups"
+	QueueRefSystemAddress QueueReference = "system_address"
+	QueueRefSalutation    QueueReference = "salutation"
+	QueueRefSignature     QueueReference = "signature"
+	QueueRefFollowUp      QueueReference = "follow_up_possible"
+)
+
+// QueueManagementRepository reads and writes queues for the queue API.
+type QueueManagementRepository struct {
+	db *sql.DB
+}
+
+// NewQueueManagementRepository creates a new queue management repository.
+func NewQueueManagementRepository(db *sql.DB) *QueueManagementRepository {
+	return &QueueManagementRepository{db: db}
+}
+
+// ListNodes returns every queue ordered by name, without tree links.
+func (r *QueueManagementRepository) ListNodes(ctx context.Context) ([]*models.QueueNode, error) {
+	rows, err := r.db.QueryContext(ctx, database.ConvertPlaceholders(`
+		SELECT id, name, group_id, valid_id
+		FROM queue
+		ORDER BY name`))
+	if err != nil {
+		return nil, fmt.Errorf("query queues: %w", err)
+	}
+	defer rows.Close()
+
+	nodes, err := database.CollectRows(rows, func(rows *sql.Rows) (*models.QueueNode, error) {
+		var n models.QueueNode
+		return &n, rows.Scan(&n.ID, &n.Name, &n.GroupID, &n.ValidID)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("scan queue: %w", err)
+	}
+	return nodes, nil
+}
+
+// Get returns the settings of a queue, or nil when it does not exist.
+func (r *QueueManagementRepository) Get(ctx context.Context, id int) (*models.QueueSettings, error) {
+	var s models.QueueSettings
+	var unlockTimeout sql.NullInt64
+	var comments sql.NullString
+	err := r.db.QueryRowContext(ctx, database.ConvertPlaceholders(`
+		SELECT name, group_id, system_address_id, salutation_id, signature_id,
+		       unlock_timeout, follow_up_id, follow_up_lock, comments, valid_id
+		FROM queue
+		WHERE id = ?`), id).Scan(
+		&s.Name, &s.GroupID, &s.SystemAddressID, &s.SalutationID, &s.SignatureID,
+		&unlockTimeout, &s.FollowUpID, &s.FollowUpLock, &comments, &s.ValidID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("load queue: %w", err)
+	}
+	s.UnlockTimeout = int(unlockTimeout.Int64)
+	if comments.Valid {
+		s.Comments = &comments.String
+	}
+	return &s, nil
+}
+
+// ReferenceValid reports whether id is a valid row of the lookup table ref.
+func (r *QueueManagementRepository) ReferenceValid(ctx context.Context, ref QueueReference, id int) (bool, error) {
+	switch ref {
+	case QueueRefGroup, QueueRefSystemAddress, QueueRefSalutation, QueueRefSignature, QueueRefFollowUp:
+	default:
+		return false, fmt.Errorf("unknown queue reference %q", ref)
+	}
+	var count int
+	// The table name comes from the fixed list above.
+	query := fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE id = ? AND valid_id = 1", ref) //nolint:gosec // G201
+	if err := r.db.QueryRowContext(ctx, database.ConvertPlaceholders(query), id).Scan(&count); err != nil {
+		return false, fmt.Errorf("check %s: %w", ref, err)
+	}
+	return count > 0, nil
+}
+
+// Create inserts a queue and returns its ID.
+func (r *QueueManagementRepository) Create(ctx context.Context, s *models.QueueSettings, userID int) (int, error) {
+	now := time.Now()
+	id, err := database.GetAdapter().InsertWithReturning(r.db, database.ConvertPlaceholders(`
+		INSERT INTO queue (name, group_id, system_address_id, salutation_id, signature_id,
+			unlock_timeout, follow_up_id, follow_up_lock, comments, valid_id,
+			create_time, create_by, change_time, change_by)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id`),
+		s.Name, s.GroupID, s.SystemAddressID, s.SalutationID, s.SignatureID,
+		s.UnlockTimeout, s.FollowUpID, s.FollowUpLock, s.Comments, s.ValidID,
+		now, userID, now, userID)
+	if err != nil {
+		return 0, fmt.Errorf("insert queue: %w", err)
+	}
+	return int(id), nil
+}
+
+// Update stores the settings of queue id and, in the same transaction,
+// renames its sub-queues. renames maps sub-queue IDs to their new names.
+func (r *QueueManagementRepository) Update(ctx context.Context, id int, s *models.QueueSettings, renames map[int]string, userID int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin queue update: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	now := time.Now()
+	_, err = tx.ExecContext(ctx, database.ConvertPlaceholders(`
+		UPDATE queue
+		SET name = ?, group_id = ?, system_address_id = ?, salutation_id = ?, signature_id = ?,
+		    unlock_timeout = ?, follow_up_id = ?, follow_up_lock = ?, comments = ?, valid_id = ?,
+		    change_time = ?, change_by = ?
+		WHERE id = ?`),
+		s.Name, s.GroupID, s.SystemAddressID, s.SalutationID, s.SignatureID,
+		s.UnlockTimeout, s.FollowUpID, s.FollowUpLock, s.Comments, s.ValidID,
+		now, userID, id)
+	if err != nil {
+		return fmt.Errorf("update queue: %w", err)
+	}
+	for subID, name := range renames {
+		if _, err := tx.ExecContext(ctx, database.ConvertPlaceholders(`
+			UPDATE queue SET name = ?, change_time = ?, change_by = ? WHERE id = ?`),
+			name, now, userID, subID); err != nil {
+			return fmt.Errorf("rename sub-queue %d: %w", subID, err)
+		}
+	}
+	return tx.Commit()
+}
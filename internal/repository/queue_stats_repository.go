@@ -0,0 +1,317 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/models"
+)
+
+// QueueStatsRepository computes and stores the daily ticket statistics of
+// queues.
+type QueueStatsRepository struct {
+	db *sql.DB
+}
+
+// NewQueueStatsRepository creates a new queue statistics repository.
+func NewQueueStatsRepository(db *sql.DB) *QueueStatsRepository {
+	return &QueueStatsRepository{db: db}
+}
+
+// Compute aggregates the statistics of every valid queue over [from, to).
+// Closed tickets are those in a closed state whose last change falls in the
+// period; their resolution time runs from creation to that change. A
+// ticket's first response is its first agent article visible to the
+// customer. With snapshot set, the current open and pending counts are
+// recorded as well.
+func (r *QueueStatsRepository) Compute(ctx context.Context, from, to time.Time, snapshot bool) ([]models.QueueStatsDay, error) {
+	days, byQueue, err := r.validQueues(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := r.db.QueryContext(ctx, database.ConvertPlaceholders(`
+		SELECT queue_id, COUNT(*) FROM ticket
+		WHERE create_time >= ? AND create_time < ?
+		GROUP BY queue_id`), from, to)
+	if err != nil {
+		return nil, fmt.Errorf("count created tickets: %w", err)
+	}
+	err = scanQueueCounts(rows, func(queueID, count int) {
+		if d := byQueue[queueID]; d != nil {
+			d.CreatedCount = count
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("scan created tickets: %w", err)
+	}
+
+	rows, err = r.db.QueryContext(ctx, database.ConvertPlaceholders(`
+		SELECT t.queue_id, t.create_time, t.change_time
+		FROM ticket t
+		JOIN ticket_state s ON s.id = t.ticket_state_id
+		JOIN ticket_state_type st ON st.id = s.type_id
+		WHERE st.name = 'closed' AND t.change_time >= ? AND t.change_time < ?`), from, to)
+	if err != nil {
+		return nil, fmt.Errorf("query closed tickets: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var queueID int
+		var created, closed time.Time
+		if err := rows.Scan(&queueID, &created, &closed); err != nil {
+			return nil, fmt.Errorf("scan closed ticket: %w", err)
+		}
+		d := byQueue[queueID]
+		if d == nil {
+			continue
+		}
+		d.ClosedCount++
+		if closed.After(created) {
+			d.ResolutionCount++
+			d.ResolutionSeconds += int64(closed.Sub(created).Seconds())
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("query closed tickets: %w", err)
+	}
+
+	// The tickets an agent answered in the period, with all their
+	// responses, so that only first responses are counted
+	rows, err = r.db.QueryContext(ctx, database.ConvertPlaceholders(`
+		SELECT t.id, t.queue_id, t.create_time, a.create_time
+		FROM ticket t
+		JOIN article a ON a.ticket_id = t.id
+		JOIN article_sender_type ast ON ast.id = a.article_sender_type_id
+		WHERE ast.name = 'agent' AND a.is_visible_for_customer = 1
+		  AND t.id IN (
+			SELECT a2.ticket_id FROM article a2
+			JOIN article_sender_type ast2 ON ast2.id = a2.article_sender_type_id
+			WHERE ast2.name = 'agent' AND a2.is_visible_for_customer = 1
+			  AND a2.create_time >= ? AND a2.create_time < ?)`), from, to)
+	if err != nil {
+		return nil, fmt.Errorf("query first responses: %w", err)
+	}
+	defer rows.Close()
+	type response struct {
+		queueID int
+		created time.Time
+		first   time.Time
+	}
+	first := make(map[int64]*response)
+	for rows.Next() {
+		var ticketID int64
+		var res response
+		if err := rows.Scan(&ticketID, &res.queueID, &res.created, &res.first); err != nil {
+			return nil, fmt.Errorf("scan first response: %w", err)
+		}
+		if prev := first[ticketID]; prev == nil || res.first.Before(prev.first) {
+			first[ticketID] = &res
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("query first responses: %w", err)
+	}
+	for _, res := range first {
+		d := byQueue[res.queueID]
+		if d == nil || res.first.Before(from) || !res.first.Before(to) {
+			continue
+		}
+		d.FirstResponseCount++
+		if res.first.After(res.created) {
+			d.FirstResponseSeconds += int64(res.first.Sub(res.created).Seconds())
+		}
+	}
+
+	if snapshot {
+		for _, d := range days {
+			open, pending := 0, 0
+			d.OpenCount, d.PendingCount = &open, &pending
+		}
+		rows, err := r.db.QueryContext(ctx, database.ConvertPlaceholders(`
+			SELECT t.queue_id, st.name, COUNT(*)
+			FROM ticket t
+			JOIN ticket_state s ON s.id = t.ticket_state_id
+			JOIN ticket_state_type st ON st.id = s.type_id
+			WHERE t.archive_flag = 0 AND st.name IN ('new', 'open', 'pending reminder', 'pending auto')
+			GROUP BY t.queue_id, st.name`))
+		if err != nil {
+			return nil, fmt.Errorf("count open tickets: %w", err)
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var queueID, count int
+			var stateType string
+			if err := rows.Scan(&queueID, &stateType, &count); err != nil {
+				return nil, fmt.Errorf("scan open tickets: %w", err)
+			}
+			d := byQueue[queueID]
+			if d == nil {
+				continue
+			}
+			if strings.HasPrefix(stateType, "pending") {
+				*d.PendingCount += count
+			} else {
+				*d.OpenCount += count
+			}
+		}
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("count open tickets: %w", err)
+		}
+	}
+
+	out := make([]models.QueueStatsDay, len(days))
+	for i, d := range days {
+		out[i] = *d
+	}
+	return out, nil
+}
+
+// validQueues returns an empty statistics row per valid queue, in queue ID
+// order and by queue ID.
+func (r *QueueStatsRepository) validQueues(ctx context.Context) ([]*models.QueueStatsDay, map[int]*models.QueueStatsDay, error) {
+	rows, err := r.db.QueryContext(ctx, "SELECT id, name FROM queue WHERE valid_id = 1 ORDER BY id")
+	if err != nil {
+		return nil, nil, fmt.Errorf("query queues: %w", err)
+	}
+	defer rows.Close()
+
+	var days []*models.QueueStatsDay
+	byQueue := make(map[int]*models.QueueStatsDay)
+	for rows.Next() {
+		d := &models.QueueStatsDay{}
+		if err := rows.Scan(&d.QueueID, &d.QueueName); err != nil {
+			return nil, nil, fmt.Errorf("scan queue: %w", err)
+		}
+		days = append(days, d)
+		byQueue[d.QueueID] = d
+	}
+	return days, byQueue, rows.Err()
+}
+
+func scanQueueCounts(rows *sql.Rows, fn func(queueID, count int)) error {
+	defer rows.Close()
+	for rows.Next() {
+		var queueID, count int
+		if err := rows.Scan(&queueID, &count); err != nil {
+			return err
+		}
+		fn(queueID, count)
+	}
+	return rows.Err()
+}
+
+// SaveDay stores the statistics of a day, replacing those it had.
+func (r *QueueStatsRepository) SaveDay(ctx context.Context, date time.Time, days []models.QueueStatsDay) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin queue stats: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := tx.ExecContext(ctx, database.ConvertPlaceholders(
+		"DELETE FROM queue_stats_daily WHERE stat_date >= ? AND stat_date < ?"), date, date.AddDate(0, 0, 1)); err != nil {
+		return fmt.Errorf("delete queue stats: %w", err)
+	}
+	now := time.Now()
+	for _, d := range days {
+		if _, err := tx.ExecContext(ctx, database.ConvertPlaceholders(`
+			INSERT INTO queue_stats_daily (queue_id, stat_date, open_count, pending_count, created_count, closed_count,
+				first_response_count, first_response_seconds, resolution_count, resolution_seconds, create_time)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`),
+			d.QueueID, date, nullableInt(d.OpenCount), nullableInt(d.PendingCount), d.CreatedCount, d.ClosedCount,
+			d.FirstResponseCount, d.FirstResponseSeconds, d.ResolutionCount, d.ResolutionSeconds, now); err != nil {
+			return fmt.Errorf("insert queue stats: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// Dates returns the days in [from, to] that have statistics, formatted as
+// 2006-01-02.
+func (r *QueueStatsRepository) Dates(ctx context.Context, from, to time.Time) (map[string]bool, error) {
+	rows, err := r.db.QueryContext(ctx, database.ConvertPlaceholders(`
+		SELECT DISTINCT stat_date FROM queue_stats_daily
+		WHERE stat_date >= ? AND stat_date < ?`), from, to.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, fmt.Errorf("query queue stats dates: %w", err)
+	}
+	defer rows.Close()
+
+	dates := make(map[string]bool)
+	for rows.Next() {
+		var date time.Time
+		if err := rows.Scan(&date); err != nil {
+			return nil, fmt.Errorf("scan queue stats date: %w", err)
+		}
+		dates[date.Format("2006-01-02")] = true
+	}
+	return dates, rows.Err()
+}
+
+// Range returns the statistics of the days in [from, to], ordered by queue
+// and day. With queueIDs set, only those queues are returned.
+func (r *QueueStatsRepository) Range(ctx context.Context, from, to time.Time, queueIDs []int) ([]models.QueueStatsDay, error) {
+	where := "s.stat_date >= ? AND s.stat_date < ?"
+	args := []interface{}{from, to.AddDate(0, 0, 1)}
+	if queueIDs != nil {
+		if len(queueIDs) == 0 {
+			return []models.QueueStatsDay{}, nil
+		}
+		placeholders := strings.TrimSuffix(strings.Repeat("?,", len(queueIDs)), ",")
+		where += " AND s.queue_id IN (" + placeholders + ")"
+		for _, id := range queueIDs {
+			args = append(args, id)
+		}
+	}
+
+	rows, err := r.db.QueryContext(ctx, database.ConvertPlaceholders(`
+		SELECT s.queue_id, COALESCE(q.name, ''), s.stat_date, s.open_count, s.pending_count,
+		       s.created_count, s.closed_count, s.first_response_count, s.first_response_seconds,
+		       s.resolution_count, s.resolution_seconds, s.create_time
+		FROM queue_stats_daily s
+		LEFT JOIN queue q ON q.id = s.queue_id
+		WHERE `+where+`
+		ORDER BY s.queue_id, s.stat_date`), args...)
+	if err != nil {
+		return nil, fmt.Errorf("query queue stats: %w", err)
+	}
+	defer rows.Close()
+
+	days := make([]models.QueueStatsDay, 0)
+	for rows.Next() {
+		var d models.QueueStatsDay
+		var open, pending sql.NullInt64
+		if err := rows.Scan(&d.QueueID, &d.QueueName, &d.Date, &open, &pending,
+			&d.CreatedCount, &d.ClosedCount, &d.FirstResponseCount, &d.FirstResponseSeconds,
+			&d.ResolutionCount, &d.ResolutionSeconds, &d.CreateTime); err != nil {
+			return nil, fmt.Errorf("scan queue stats: %w", err)
+		}
+		if open.Valid {
+			n := int(open.Int64)
+			d.OpenCount = &n
+		}
+		if pending.Valid {
+			n := int(pending.Int64)
+			d.PendingCount = &n
+		}
+		days = append(days, d)
+	}
+	return days, rows.Err()
+}
+
+// DeleteBefore removes the statistics of days before date and returns how
+// many rows were removed.
+func (r *QueueStatsRepository) DeleteBefore(ctx context.Context, date time.Time) (int64, error) {
+	result, err := r.db.ExecContext(ctx, database.ConvertPlaceholders(
+		"DELETE FROM queue_stats_daily WHERE stat_date < ?"), date)
+	if err != nil {
+		return 0, fmt.Errorf("delete queue stats: %w", err)
+	}
+	n, _ := result.RowsAffected()
+	return n, nil
+}
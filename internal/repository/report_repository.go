@@ -0,0 +1,328 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/models"
+)
+
+const reportDefinitionSelect = `
+	SELECT id, name, COALESCE(description, ''), metric, group_by, time_bucket,
+	       COALESCE(filters, ''), COALESCE(schedule, ''), COALESCE(schedule_format, ''),
+	       COALESCE(recipient_group_ids, ''), last_sent_time, valid_id,
+	       create_time, create_by, change_time, change_by
+	FROM report_definition`
+
+// ReportRepository handles database operations for saved reports.
+type ReportRepository struct {
+	db *sql.DB
+}
+
+// NewReportRepository creates a new report repository.
+func NewReportRepository(db *sql.DB) *ReportRepository {
+	return &ReportRepository{db: db}
+}
+
+// List returns all valid report definitions ordered by name.
+func (r *ReportRepository) List(ctx context.Context) ([]models.ReportDefinition, error) {
+	return r.query(ctx, reportDefinitionSelect+" WHERE valid_id = 1 ORDER BY name")
+}
+
+// ListScheduled returns valid report definitions that have a schedule.
+func (r *ReportRepository) ListScheduled(ctx context.Context) ([]models.ReportDefinition, error) {
+	return r.query(ctx, reportDefinitionSelect+" WHERE valid_id = 1 AND schedule IS NOT NULL AND schedule <> '' ORDER BY id")
+}
+
+// Get returns a report definition by ID, or nil if it does not exist.
+func (r *ReportRepository) Get(ctx context.Context, id int) (*models.ReportDefinition, error) {
+	row := r.db.QueryRowContext(ctx, database.ConvertPlaceholders(reportDefinitionSelect+" WHERE id = ? AND valid_id = 1"), id)
+	def, err := scanReportDefinition(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get report definition: %w", err)
+	}
+	return def, nil
+}
+
+// NameExists reports whether another valid report already uses the name.
+func (r *ReportRepository) NameExists(ctx context.Context, name string, excludeID int) (bool, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx, database.ConvertPlaceholders(
+		"SELECT COUNT(*) FROM report_definition WHERE name = ? AND id <> ? AND valid_id = 1",
+	), name, excludeID).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("check report name: %w", err)
+	}
+	return count > 0, nil
+}
+
+// Create inserts a report definition and returns its ID.
+func (r *ReportRepository) Create(ctx context.Context, def *models.ReportDefinition) (int, error) {
+	filters, recipients, err := encodeReportJSON(def)
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	query := database.ConvertPlaceholders(`
+		INSERT INTO report_definition (name, description, metric, group_by, time_bucket, filters,
+			schedule, schedule_format, recipient_group_ids, valid_id,
+			create_time, create_by, change_time, change_by)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, 1, ?, ?, ?, ?)
+		RETURNING id`)
+	id, err := database.GetAdapter().InsertWithReturning(r.db, query,
+		def.Name, def.Description, string(def.Metric), string(def.GroupBy), string(def.TimeBucket), filters,
+		def.Schedule, string(def.ScheduleFormat), recipients,
+		now, def.CreateBy, now, def.CreateBy)
+	if err != nil {
+		return 0, fmt.Errorf("insert report definition: %w", err)
+	}
+	return int(id), nil
+}
+
+// Update stores changes to a report definition.
+func (r *ReportRepository) Update(ctx context.Context, def *models.ReportDefinition, userID int) error {
+	filters, recipients, err := encodeReportJSON(def)
+	if err != nil {
+		return err
+	}
+
+	result, err := r.db.ExecContext(ctx, database.ConvertPlaceholders(`
+		UPDATE report_definition
+		SET name = ?, description = ?, metric = ?, group_by = ?, time_bucket = ?, filters = ?,
+		    schedule = ?, schedule_format = ?, recipient_group_ids = ?, change_time = ?, change_by = ?
+		WHERE id = ? AND valid_id = 1
+	`), def.Name, def.Description, string(def.Metric), string(def.GroupBy), string(def.TimeBucket), filters,
+		def.Schedule, string(def.ScheduleFormat), recipients, time.Now(), userID, def.ID)
+	if err != nil {
+		return fmt.Errorf("update report definition: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// Invalidate soft-deletes a report definition by marking it invalid.
+func (r *ReportRepository) Invalidate(ctx context.Context, id int, userID int) error {
+	result, err := r.db.ExecContext(ctx, database.ConvertPlaceholders(`
+		UPDATE report_definition SET valid_id = 2, change_time = ?, change_by = ?
+		WHERE id = ? AND valid_id = 1
+	`), time.Now(), userID, id)
+	if err != nil {
+		return fmt.Errorf("invalidate report definition: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// MarkSent records when a scheduled report was last delivered.
+func (r *ReportRepository) MarkSent(ctx context.Context, id int, sentAt time.Time) error {
+	_, err := r.db.ExecContext(ctx, database.ConvertPlaceholders(
+		"UPDATE report_definition SET last_sent_time = ? WHERE id = ?",
+	), sentAt, id)
+	if err != nil {
+		return fmt.Errorf("mark report sent: %w", err)
+	}
+	return nil
+}
+
+// Tickets returns the tickets a report over [from, to) is computed from.
+// Creation metrics select by create_time; close metrics select closed
+// tickets by change_time, the time of their last state change. Survey
+// metrics return a row per survey answered in the period, with its time as
+// ChangeTime.
+func (r *ReportRepository) Tickets(ctx context.Context, metric models.ReportMetric, filters models.ReportFilters, from, to time.Time) ([]models.ReportTicket, error) {
+	var where []string
+	var args []interface{}
+
+	changeTime, join := "t.change_time", ""
+	switch {
+	case metric == models.ReportMetricTicketsCreated:
+		where = append(where, "t.create_time >= ? AND t.create_time < ?")
+	case metric.IsSurvey():
+		changeTime = "sr.answered_at"
+		join = `
+		JOIN survey_request sr ON sr.ticket_id = t.id
+		LEFT JOIN (
+			SELECT request_id, AVG(rating) AS rating
+			FROM survey_response
+			WHERE question_type = 'rating' AND rating IS NOT NULL
+			GROUP BY request_id
+		) sa ON sa.request_id = sr.id`
+		where = append(where, "sr.answered_at >= ? AND sr.answered_at < ?")
+		if metric == models.ReportMetricAvgSatisfaction {
+			where = append(where, "sa.rating IS NOT NULL")
+		}
+	default:
+		where = append(where, "ts.type_id = 3 AND t.change_time >= ? AND t.change_time < ?")
+	}
+	args = append(args, from, to)
+
+	for _, f := range []struct {
+		column string
+		ids    []int
+	}{
+		{"t.queue_id", filters.QueueIDs},
+		{"t.ticket_state_id", filters.StateIDs},
+		{"t.ticket_priority_id", filters.PriorityIDs},
+		{"t.type_id", filters.TypeIDs},
+	} {
+		if len(f.ids) == 0 {
+			continue
+		}
+		placeholders := make([]string, len(f.ids))
+		for i, id := range f.ids {
+			placeholders[i] = "?"
+			args = append(args, id)
+		}
+		where = append(where, f.column+" IN ("+strings.Join(placeholders, ",")+")")
+	}
+
+	rating := "0"
+	if metric.IsSurvey() {
+		rating = "COALESCE(sa.rating, 0)"
+	}
+	query := `
+		SELECT t.create_time, ` + changeTime + `, ts.type_id,
+		       COALESCE(q.name, ''), COALESCE(ts.name, ''), COALESCE(tp.name, ''),
+		       COALESCE(u.login, ''), COALESCE(t.customer_id, ''), COALESCE(tt.name, ''), ` + rating + `
+		FROM ticket t` + join + `
+		LEFT JOIN queue q ON q.id = t.queue_id
+		LEFT JOIN ticket_state ts ON ts.id = t.ticket_state_id
+		LEFT JOIN ticket_priority tp ON tp.id = t.ticket_priority_id
+		LEFT JOIN users u ON u.id = t.user_id
+		LEFT JOIN ticket_type tt ON tt.id = t.type_id
+		WHERE ` + strings.Join(where, " AND ")
+
+	rows, err := r.db.QueryContext(ctx, database.ConvertPlaceholders(query), args...)
+	if err != nil {
+		return nil, fmt.Errorf("query report tickets: %w", err)
+	}
+	defer rows.Close()
+
+	tickets := make([]models.ReportTicket, 0)
+	for rows.Next() {
+		var t models.ReportTicket
+		var typeID sql.NullInt64
+		if err := rows.Scan(&t.CreateTime, &t.ChangeTime, &typeID,
+			&t.Queue, &t.State, &t.Priority, &t.Owner, &t.Customer, &t.Type, &t.Rating); err != nil {
+			return nil, fmt.Errorf("scan report ticket: %w", err)
+		}
+		t.Closed = typeID.Valid && typeID.Int64 == 3
+		tickets = append(tickets, t)
+	}
+	return tickets, rows.Err()
+}
+
+// RecipientEmails returns the addresses of valid agents in the given groups.
+// Agent logins are email addresses; logins without an @ are skipped.
+func (r *ReportRepository) RecipientEmails(ctx context.Context, groupIDs []int) ([]string, error) {
+	if len(groupIDs) == 0 {
+		return nil, nil
+	}
+	placeholders := make([]string, len(groupIDs))
+	args := make([]interface{}, len(groupIDs))
+	for i, id := range groupIDs {
+		placeholders[i] = "?"
+		args[i] = id
+	}
+
+	rows, err := r.db.QueryContext(ctx, database.ConvertPlaceholders(`
+		SELECT DISTINCT u.login
+		FROM users u
+		JOIN group_user gu ON gu.user_id = u.id
+		JOIN groups g ON g.id = gu.group_id
+		WHERE u.valid_id = 1 AND g.valid_id = 1 AND gu.group_id IN (`+strings.Join(placeholders, ",")+`)
+		ORDER BY u.login
+	`), args...)
+	if err != nil {
+		return nil, fmt.Errorf("query report recipients: %w", err)
+	}
+	defer rows.Close()
+
+	emails := make([]string, 0)
+	for rows.Next() {
+		var login string
+		if err := rows.Scan(&login); err != nil {
+			return nil, fmt.Errorf("scan report recipient: %w", err)
+		}
+		if strings.Contains(login, "@") {
+			emails = append(emails, login)
+		}
+	}
+	return emails, rows.Err()
+}
+
+func (r *ReportRepository) query(ctx context.Context, query string, args ...interface{}) ([]models.ReportDefinition, error) {
+	rows, err := r.db.QueryContext(ctx, database.ConvertPlaceholders(query), args...)
+	if err != nil {
+		return nil, fmt.Errorf("query report definitions: %w", err)
+	}
+	defer rows.Close()
+
+	defs := make([]models.ReportDefinition, 0)
+	for rows.Next() {
+		def, err := scanReportDefinition(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan report definition: %w", err)
+		}
+		defs = append(defs, *def)
+	}
+	return defs, rows.Err()
+}
+
+func encodeReportJSON(def *models.ReportDefinition) (string, string, error) {
+	filters, err := json.Marshal(def.Filters)
+	if err != nil {
+		return "", "", fmt.Errorf("encode report filters: %w", err)
+	}
+	recipients := "[]"
+	if len(def.RecipientGroupIDs) > 0 {
+		b, err := json.Marshal(def.RecipientGroupIDs)
+		if err != nil {
+			return "", "", fmt.Errorf("encode report recipients: %w", err)
+		}
+		recipients = string(b)
+	}
+	return string(filters), recipients, nil
+}
+
+func scanReportDefinition(row kbRowScanner) (*models.ReportDefinition, error) {
+	var d models.ReportDefinition
+	var metric, groupBy, bucket, filters, format, recipients string
+	var lastSent sql.NullTime
+	if err := row.Scan(&d.ID, &d.Name, &d.Description, &metric, &groupBy, &bucket,
+		&filters, &d.Schedule, &format, &recipients, &lastSent, &d.ValidID,
+		&d.CreateTime, &d.CreateBy, &d.ChangeTime, &d.ChangeBy); err != nil {
+		return nil, err
+	}
+	d.Metric = models.ReportMetric(metric)
+	d.GroupBy = models.ReportGrouping(groupBy)
+	d.TimeBucket = models.ReportBucket(bucket)
+	d.ScheduleFormat = models.ReportFormat(format)
+	if lastSent.Valid {
+		d.LastSentTime = &lastSent.Time
+	}
+	if filters != "" {
+		if err := json.Unmarshal([]byte(filters), &d.Filters); err != nil {
+			return nil, fmt.Errorf("decode report filters: %w", err)
+		}
+	}
+	if recipients != "" {
+		if err := json.Unmarshal([]byte(recipients), &d.RecipientGroupIDs); err != nil {
+			return nil, fmt.Errorf("decode report recipients: %w", err)
+		}
+	}
+	return &d, nil
+}
@@ -0,0 +1,243 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/models"
+)
+
+const ssoProviderSelect = `
+	SELECT id, name, protocol, frontend, config, COALESCE(group_mappings, ''), jit_provisioning, valid_id,
+	       create_time, create_by, change_time, change_by
+	FROM sso_provider`
+
+// SSORepository handles database operations for single sign-on providers.
+type SSORepository struct {
+	db *sql.DB
+}
+
+// NewSSORepository creates a new SSO provider repository.
+func NewSSORepository(db *sql.DB) *SSORepository {
+	return &SSORepository{db: db}
+}
+
+// List returns every provider ordered by name.
+func (r *SSORepository) List(ctx context.Context) ([]models.SSOProvider, error) {
+	return r.query(ctx, ssoProviderSelect+" ORDER BY name")
+}
+
+// ListEnabled returns the valid providers for a frontend, for the login page.
+func (r *SSORepository) ListEnabled(ctx context.Context, frontend models.SSOFrontend) ([]models.SSOProvider, error) {
+	return r.query(ctx, ssoProviderSelect+" WHERE frontend = ? AND valid_id = 1 ORDER BY name", string(frontend))
+}
+
+func (r *SSORepository) query(ctx context.Context, query string, args ...interface{}) ([]models.SSOProvider, error) {
+	rows, err := r.db.QueryContext(ctx, database.ConvertPlaceholders(query), args...)
+	if err != nil {
+		return nil, fmt.Errorf("query sso providers: %w", err)
+	}
+	defer rows.Close()
+
+	providers := make([]models.SSOProvider, 0)
+	for rows.Next() {
+		p, err := scanSSOProvider(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan sso provider: %w", err)
+		}
+		providers = append(providers, *p)
+	}
+	return providers, rows.Err()
+}
+
+// Get returns a provider by ID, or nil if it does not exist.
+func (r *SSORepository) Get(ctx context.Context, id int) (*models.SSOProvider, error) {
+	row := r.db.QueryRowContext(ctx, database.ConvertPlaceholders(ssoProviderSelect+" WHERE id = ?"), id)
+	p, err := scanSSOProvider(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get sso provider: %w", err)
+	}
+	return p, nil
+}
+
+// NameExists reports whether another provider already uses the name.
+func (r *SSORepository) NameExists(ctx context.Context, name string, excludeID int) (bool, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx, database.ConvertPlaceholders(
+		"SELECT COUNT(*) FROM sso_provider WHERE name = ? AND id <> ?",
+	), name, excludeID).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("check sso provider name: %w", err)
+	}
+	return count > 0, nil
+}
+
+// Create inserts a provider and returns its ID.
+func (r *SSORepository) Create(ctx context.Context, p *models.SSOProvider) (int, error) {
+	config, mappings, jit, err := encodeSSOProvider(p)
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now()
+	query := database.ConvertPlaceholders(`
+		INSERT INTO sso_provider (name, protocol, frontend, config, group_mappings, jit_provisioning, valid_id,
+			create_time, create_by, change_time, change_by)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id`)
+	id, err := database.GetAdapter().InsertWithReturning(r.db, query,
+		p.Name, string(p.Protocol), string(p.Frontend), config, mappings, jit, p.ValidID,
+		now, p.CreateBy, now, p.CreateBy)
+	if err != nil {
+		return 0, fmt.Errorf("insert sso provider: %w", err)
+	}
+	return int(id), nil
+}
+
+// Update stores changes to a provider.
+func (r *SSORepository) Update(ctx context.Context, p *models.SSOProvider, userID int) error {
+	config, mappings, jit, err := encodeSSOProvider(p)
+	if err != nil {
+		return err
+	}
+	result, err := r.db.ExecContext(ctx, database.ConvertPlaceholders(`
+		UPDATE sso_provider
+		SET name = ?, protocol = ?, frontend = ?, config = ?, group_mappings = ?, jit_provisioning = ?, valid_id = ?,
+		    change_time = ?, change_by = ?
+		WHERE id = ?
+	`), p.Name, string(p.Protocol), string(p.Frontend), config, mappings, jit, p.ValidID,
+		time.Now(), userID, p.ID)
+	if err != nil {
+		return fmt.Errorf("update sso provider: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// Delete removes a provider. Accounts it created are kept.
+func (r *SSORepository) Delete(ctx context.Context, id int) error {
+	result, err := r.db.ExecContext(ctx, database.ConvertPlaceholders("DELETE FROM sso_provider WHERE id = ?"), id)
+	if err != nil {
+		return fmt.Errorf("delete sso provider: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// LinkedUser returns the account linked to a provider's subject, or 0 if
+// there is none.
+func (r *SSORepository) LinkedUser(ctx context.Context, providerID int, subject string) (int, error) {
+	var userID int
+	err := r.db.QueryRowContext(ctx, database.ConvertPlaceholders(
+		"SELECT user_id FROM sso_identity WHERE provider_id = ? AND subject = ?",
+	), providerID, subject).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("get sso identity: %w", err)
+	}
+	return userID, nil
+}
+
+// CreateLink links an account to a provider's subject.
+func (r *SSORepository) CreateLink(ctx context.Context, providerID int, subject string, userID, createBy int) error {
+	_, err := r.db.ExecContext(ctx, database.ConvertPlaceholders(`
+		INSERT INTO sso_identity (provider_id, subject, user_id, create_time, create_by)
+		VALUES (?, ?, ?, ?, ?)`), providerID, subject, userID, time.Now(), createBy)
+	if err != nil {
+		return fmt.Errorf("insert sso identity: %w", err)
+	}
+	return nil
+}
+
+// DeleteLink removes the link of a provider's subject.
+func (r *SSORepository) DeleteLink(ctx context.Context, providerID int, subject string) error {
+	result, err := r.db.ExecContext(ctx, database.ConvertPlaceholders(
+		"DELETE FROM sso_identity WHERE provider_id = ? AND subject = ?"), providerID, subject)
+	if err != nil {
+		return fmt.Errorf("delete sso identity: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// ListLinks returns a provider's links with the login of each account;
+// accountTable is users or customer_user, by the provider's frontend.
+func (r *SSORepository) ListLinks(ctx context.Context, providerID int, accountTable string) ([]models.SSOLink, error) {
+	rows, err := r.db.QueryContext(ctx, database.ConvertPlaceholders(`
+		SELECT i.provider_id, i.subject, i.user_id, COALESCE(a.login, ''), i.create_time, i.create_by
+		FROM sso_identity i
+		LEFT JOIN `+accountTable+` a ON a.id = i.user_id
+		WHERE i.provider_id = ?
+		ORDER BY a.login, i.subject`), providerID)
+	if err != nil {
+		return nil, fmt.Errorf("query sso identities: %w", err)
+	}
+	defer rows.Close()
+
+	links := make([]models.SSOLink, 0)
+	for rows.Next() {
+		var l models.SSOLink
+		if err := rows.Scan(&l.ProviderID, &l.Subject, &l.UserID, &l.Login, &l.CreateTime, &l.CreateBy); err != nil {
+			return nil, fmt.Errorf("scan sso identity: %w", err)
+		}
+		links = append(links, l)
+	}
+	return links, rows.Err()
+}
+
+func encodeSSOProvider(p *models.SSOProvider) (string, string, int, error) {
+	config, err := json.Marshal(p.Config)
+	if err != nil {
+		return "", "", 0, fmt.Errorf("encode sso provider config: %w", err)
+	}
+	mappings := p.GroupMappings
+	if mappings == nil {
+		mappings = []models.SSOGroupMapping{}
+	}
+	encoded, err := json.Marshal(mappings)
+	if err != nil {
+		return "", "", 0, fmt.Errorf("encode sso group mappings: %w", err)
+	}
+	jit := 0
+	if p.JITProvisioning {
+		jit = 1
+	}
+	return string(config), string(encoded), jit, nil
+}
+
+func scanSSOProvider(row kbRowScanner) (*models.SSOProvider, error) {
+	var p models.SSOProvider
+	var protocol, frontend, config, mappings string
+	var jit int
+	if err := row.Scan(&p.ID, &p.Name, &protocol, &frontend, &config, &mappings, &jit, &p.ValidID,
+		&p.CreateTime, &p.CreateBy, &p.ChangeTime, &p.ChangeBy); err != nil {
+		return nil, err
+	}
+	p.Protocol = models.SSOProtocol(protocol)
+	p.Frontend = models.SSOFrontend(frontend)
+	p.JITProvisioning = jit == 1
+	if err := json.Unmarshal([]byte(config), &p.Config); err != nil {
+		return nil, fmt.Errorf("decode sso provider %d config: %w", p.ID, err)
+	}
+	p.GroupMappings = []models.SSOGroupMapping{}
+	if mappings != "" {
+		if err := json.Unmarshal([]byte(mappings), &p.GroupMappings); err != nil {
+			return nil, fmt.Errorf("decode sso provider %d group mappings: %w", p.ID, err)
+		}
+	}
+	return &p, nil
+}
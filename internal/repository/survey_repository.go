@@ -0,0 +1,442 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/models"
+)
+
+// ErrSurveyAnswered is returned when a survey request was answered before.
+var ErrSurveyAnswered = errors.New("survey was already answered")
+
+const surveySelect = `
+	SELECT id, name, COALESCE(description, ''), questions, COALESCE(queue_ids, ''),
+	       send_delay_minutes, link_valid_days, email_subject, email_body, valid_id,
+	       create_time, create_by, change_time, change_by
+	FROM survey`
+
+const surveyRequestSelect = `
+	SELECT r.id, r.survey_id, r.ticket_id, COALESCE(t.tn, ''), COALESCE(t.customer_user_id, ''),
+	       r.sent_at, r.expires_at, r.answered_at
+	FROM survey_request r
+	LEFT JOIN ticket t ON t.id = r.ticket_id`
+
+// SurveyRepository stores satisfaction surveys, the requests sent for
+// tickets and their responses.
+type SurveyRepository struct {
+	db *sql.DB
+}
+
+// NewSurveyRepository creates a new survey repository.
+func NewSurveyRepository(db *sql.DB) *SurveyRepository {
+	return &SurveyRepository{db: db}
+}
+
+// List returns all surveys ordered by name. With validOnly, disabled
+// surveys are left out.
+func (r *SurveyRepository) List(ctx context.Context, validOnly bool) ([]*models.Survey, error) {
+	query := surveySelect
+	if validOnly {
+		query += " WHERE valid_id = 1"
+	}
+	rows, err := r.db.QueryContext(ctx, database.ConvertPlaceholders(query+" ORDER BY name"))
+	if err != nil {
+		return nil, fmt.Errorf("query surveys: %w", err)
+	}
+	defer rows.Close()
+
+	var surveys []*models.Survey
+	for rows.Next() {
+		s, err := scanSurvey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan survey: %w", err)
+		}
+		surveys = append(surveys, s)
+	}
+	return surveys, rows.Err()
+}
+
+// Get returns a survey, or nil if it does not exist.
+func (r *SurveyRepository) Get(ctx context.Context, id int) (*models.Survey, error) {
+	s, err := scanSurvey(r.db.QueryRowContext(ctx, database.ConvertPlaceholders(surveySelect+" WHERE id = ?"), id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query survey: %w", err)
+	}
+	return s, nil
+}
+
+// NameExists reports whether another survey already uses the name.
+func (r *SurveyRepository) NameExists(ctx context.Context, name string, excludeID int) (bool, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx, database.ConvertPlaceholders(
+		"SELECT COUNT(*) FROM survey WHERE name = ? AND id <> ?"), name, excludeID).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("check survey name: %w", err)
+	}
+	return count > 0, nil
+}
+
+// Create inserts a survey, setting its ID.
+func (r *SurveyRepository) Create(ctx context.Context, s *models.Survey) error {
+	questions, queueIDs, err := encodeSurveyJSON(s)
+	if err != nil {
+		return err
+	}
+	id, err := database.GetAdapter().InsertWithReturning(r.db, database.ConvertPlaceholders(`
+		INSERT INTO survey (name, description, questions, queue_ids, send_delay_minutes, link_valid_days,
+			email_subject, email_body, valid_id, create_time, create_by, change_time, change_by)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id`),
+		s.Name, s.Description, questions, queueIDs, s.SendDelayMinutes, s.LinkValidDays,
+		s.EmailSubject, s.EmailBody, s.ValidID, s.CreateTime, s.CreateBy, s.ChangeTime, s.ChangeBy)
+	if err != nil {
+		return fmt.Errorf("insert survey: %w", err)
+	}
+	s.ID = int(id)
+	return nil
+}
+
+// Update stores changes to a survey. It returns sql.ErrNoRows when the
+// survey does not exist.
+func (r *SurveyRepository) Update(ctx context.Context, s *models.Survey) error {
+	questions, queueIDs, err := encodeSurveyJSON(s)
+	if err != nil {
+		return err
+	}
+	result, err := r.db.ExecContext(ctx, database.ConvertPlaceholders(`
+		UPDATE survey
+		SET name = ?, description = ?, questions = ?, queue_ids = ?, send_delay_minutes = ?, link_valid_days = ?,
+		    email_subject = ?, email_body = ?, valid_id = ?, change_time = ?, change_by = ?
+		WHERE id = ?`),
+		s.Name, s.Description, questions, queueIDs, s.SendDelayMinutes, s.LinkValidDays,
+		s.EmailSubject, s.EmailBody, s.ValidID, s.ChangeTime, s.ChangeBy, s.ID)
+	if err != nil {
+		return fmt.Errorf("update survey: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// Delete deletes a survey, reporting whether it existed.
+func (r *SurveyRepository) Delete(ctx context.Context, id int) (bool, error) {
+	result, err := r.db.ExecContext(ctx, database.ConvertPlaceholders("DELETE FROM survey WHERE id = ?"), id)
+	if err != nil {
+		return false, fmt.Errorf("delete survey: %w", err)
+	}
+	n, _ := result.RowsAffected()
+	return n > 0, nil
+}
+
+// HasRequests reports whether the survey was sent for any ticket.
+func (r *SurveyRepository) HasRequests(ctx context.Context, surveyID int) (bool, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx, database.ConvertPlaceholders(
+		"SELECT COUNT(*) FROM survey_request WHERE survey_id = ?"), surveyID).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("count survey requests: %w", err)
+	}
+	return count > 0, nil
+}
+
+// Candidates returns tickets in a closed state whose last change lies in
+// [closedAfter, closedBefore), whose customer user has an email address
+// and that were not surveyed yet. With queueIDs, only tickets of those
+// queues are returned.
+func (r *SurveyRepository) Candidates(ctx context.Context, queueIDs []int, closedAfter, closedBefore time.Time, limit int) ([]models.SurveyCandidate, error) {
+	where := []string{
+		"st.name = 'closed'",
+		"t.change_time >= ? AND t.change_time < ?",
+		"((cu.email IS NOT NULL AND cu.email <> '') OR t.customer_user_id LIKE '%@%')",
+		"NOT EXISTS (SELECT 1 FROM survey_request sr WHERE sr.ticket_id = t.id)",
+	}
+	args := []interface{}{closedAfter, closedBefore}
+	if len(queueIDs) > 0 {
+		placeholders := make([]string, len(queueIDs))
+		for i, id := range queueIDs {
+			placeholders[i] = "?"
+			args = append(args, id)
+		}
+		where = append(where, "t.queue_id IN ("+strings.Join(placeholders, ",")+")")
+	}
+	args = append(args, limit)
+
+	rows, err := r.db.QueryContext(ctx, database.ConvertPlaceholders(`
+		SELECT t.id, t.tn, COALESCE(t.title, ''), t.queue_id, t.customer_user_id, COALESCE(cu.email, '')
+		FROM ticket t
+		JOIN ticket_state s ON s.id = t.ticket_state_id
+		JOIN ticket_state_type st ON st.id = s.type_id
+		LEFT JOIN customer_user cu ON cu.login = t.customer_user_id
+		WHERE `+strings.Join(where, " AND ")+`
+		ORDER BY t.change_time, t.id
+		LIMIT ?`), args...)
+	if err != nil {
+		return nil, fmt.Errorf("query survey candidates: %w", err)
+	}
+	defer rows.Close()
+
+	var candidates []models.SurveyCandidate
+	for rows.Next() {
+		var c models.SurveyCandidate
+		if err := rows.Scan(&c.TicketID, &c.TicketNumber, &c.Title, &c.QueueID, &c.CustomerUserID, &c.Email); err != nil {
+			return nil, fmt.Errorf("scan survey candidate: %w", err)
+		}
+		if c.Email == "" && strings.Contains(c.CustomerUserID, "@") {
+			c.Email = c.CustomerUserID
+		}
+		candidates = append(candidates, c)
+	}
+	return candidates, rows.Err()
+}
+
+// CreateRequest stores a survey sent for a ticket with the hash of its
+// link token, setting its ID.
+func (r *SurveyRepository) CreateRequest(ctx context.Context, req *models.SurveyRequest, tokenHash string) error {
+	id, err := database.GetAdapter().InsertWithReturning(r.db, database.ConvertPlaceholders(`
+		INSERT INTO survey_request (survey_id, ticket_id, token_hash, sent_at, expires_at)
+		VALUES (?, ?, ?, ?, ?)
+		RETURNING id`),
+		req.SurveyID, req.TicketID, tokenHash, req.SentAt, req.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("insert survey request: %w", err)
+	}
+	req.ID = id
+	return nil
+}
+
+// DeleteRequest deletes a survey request with its answers.
+func (r *SurveyRepository) DeleteRequest(ctx context.Context, id int64) error {
+	if _, err := r.db.ExecContext(ctx, database.ConvertPlaceholders(
+		"DELETE FROM survey_response WHERE request_id = ?"), id); err != nil {
+		return fmt.Errorf("delete survey answers: %w", err)
+	}
+	if _, err := r.db.ExecContext(ctx, database.ConvertPlaceholders(
+		"DELETE FROM survey_request WHERE id = ?"), id); err != nil {
+		return fmt.Errorf("delete survey request: %w", err)
+	}
+	return nil
+}
+
+// RequestByTokenHash returns the survey request with the token hash, or
+// nil if there is none.
+func (r *SurveyRepository) RequestByTokenHash(ctx context.Context, tokenHash string) (*models.SurveyRequest, error) {
+	req, err := scanSurveyRequest(r.db.QueryRowContext(ctx,
+		database.ConvertPlaceholders(surveyRequestSelect+" WHERE r.token_hash = ?"), tokenHash))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query survey request: %w", err)
+	}
+	return req, nil
+}
+
+// SaveAnswers stores the answers to a survey request and marks it answered.
+// It returns ErrSurveyAnswered when it was answered before.
+func (r *SurveyRepository) SaveAnswers(ctx context.Context, requestID int64, answers []models.SurveyAnswer, now time.Time) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin survey answers: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	res, err := tx.ExecContext(ctx, database.ConvertPlaceholders(
+		"UPDATE survey_request SET answered_at = ? WHERE id = ? AND answered_at IS NULL"), now, requestID)
+	if err != nil {
+		return fmt.Errorf("mark survey answered: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrSurveyAnswered
+	}
+	for _, a := range answers {
+		var answer interface{}
+		if a.Answer != "" {
+			answer = a.Answer
+		}
+		if _, err := tx.ExecContext(ctx, database.ConvertPlaceholders(`
+			INSERT INTO survey_response (request_id, question_id, question_type, rating, answer, create_time)
+			VALUES (?, ?, ?, ?, ?, ?)`),
+			requestID, a.QuestionID, string(a.QuestionType), nullableInt(a.Rating), answer, now); err != nil {
+			return fmt.Errorf("insert survey answer: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// ListResponses returns the answered requests of a survey with their
+// answers, most recently answered first.
+func (r *SurveyRepository) ListResponses(ctx context.Context, surveyID, limit, offset int) ([]*models.SurveyRequest, error) {
+	rows, err := r.db.QueryContext(ctx, database.ConvertPlaceholders(surveyRequestSelect+`
+		WHERE r.survey_id = ? AND r.answered_at IS NOT NULL
+		ORDER BY r.answered_at DESC, r.id DESC
+		LIMIT ? OFFSET ?`), surveyID, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("query survey responses: %w", err)
+	}
+	var list []*models.SurveyRequest
+	byID := make(map[int64]*models.SurveyRequest)
+	for rows.Next() {
+		req, err := scanSurveyRequest(rows)
+		if err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scan survey request: %w", err)
+		}
+		list = append(list, req)
+		byID[req.ID] = req
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil || len(list) == 0 {
+		return list, err
+	}
+
+	placeholders := make([]string, len(list))
+	args := make([]interface{}, len(list))
+	for i, req := range list {
+		placeholders[i] = "?"
+		args[i] = req.ID
+	}
+	answers, err := r.db.QueryContext(ctx, database.ConvertPlaceholders(`
+		SELECT request_id, question_id, question_type, rating, COALESCE(answer, '')
+		FROM survey_response
+		WHERE request_id IN (`+strings.Join(placeholders, ",")+`)
+		ORDER BY id`), args...)
+	if err != nil {
+		return nil, fmt.Errorf("query survey answers: %w", err)
+	}
+	defer answers.Close()
+	for answers.Next() {
+		var requestID int64
+		a, err := scanSurveyAnswer(answers, &requestID)
+		if err != nil {
+			return nil, fmt.Errorf("scan survey answer: %w", err)
+		}
+		if req := byID[requestID]; req != nil {
+			req.Answers = append(req.Answers, a)
+		}
+	}
+	return list, answers.Err()
+}
+
+// CountRequests returns how many requests of a survey were sent in
+// [from, to) and how many of them were answered. A surveyID of 0 counts
+// the requests of all surveys.
+func (r *SurveyRepository) CountRequests(ctx context.Context, surveyID int, from, to time.Time) (int, int, error) {
+	query := `
+		SELECT COUNT(*), COALESCE(SUM(CASE WHEN answered_at IS NULL THEN 0 ELSE 1 END), 0)
+		FROM survey_request
+		WHERE sent_at >= ? AND sent_at < ?`
+	args := []interface{}{from, to}
+	if surveyID > 0 {
+		query += " AND survey_id = ?"
+		args = append(args, surveyID)
+	}
+	var sent, answered int
+	if err := r.db.QueryRowContext(ctx, database.ConvertPlaceholders(query), args...).Scan(&sent, &answered); err != nil {
+		return 0, 0, fmt.Errorf("count survey requests: %w", err)
+	}
+	return sent, answered, nil
+}
+
+// Answers returns the answers to the requests of a survey sent in
+// [from, to). A surveyID of 0 returns the answers to all surveys.
+func (r *SurveyRepository) Answers(ctx context.Context, surveyID int, from, to time.Time) ([]models.SurveyAnswer, error) {
+	query := `
+		SELECT a.request_id, a.question_id, a.question_type, a.rating, COALESCE(a.answer, '')
+		FROM survey_response a
+		JOIN survey_request r ON r.id = a.request_id
+		WHERE r.sent_at >= ? AND r.sent_at < ?`
+	args := []interface{}{from, to}
+	if surveyID > 0 {
+		query += " AND r.survey_id = ?"
+		args = append(args, surveyID)
+	}
+	rows, err := r.db.QueryContext(ctx, database.ConvertPlaceholders(query+" ORDER BY a.id"), args...)
+	if err != nil {
+		return nil, fmt.Errorf("query survey answers: %w", err)
+	}
+	defer rows.Close()
+
+	answers := make([]models.SurveyAnswer, 0)
+	for rows.Next() {
+		var requestID int64
+		a, err := scanSurveyAnswer(rows, &requestID)
+		if err != nil {
+			return nil, fmt.Errorf("scan survey answer: %w", err)
+		}
+		answers = append(answers, a)
+	}
+	return answers, rows.Err()
+}
+
+func scanSurvey(row kbRowScanner) (*models.Survey, error) {
+	var s models.Survey
+	var questions, queueIDs string
+	if err := row.Scan(&s.ID, &s.Name, &s.Description, &questions, &queueIDs,
+		&s.SendDelayMinutes, &s.LinkValidDays, &s.EmailSubject, &s.EmailBody, &s.ValidID,
+		&s.CreateTime, &s.CreateBy, &s.ChangeTime, &s.ChangeBy); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(questions), &s.Questions); err != nil {
+		return nil, fmt.Errorf("decode questions of survey %d: %w", s.ID, err)
+	}
+	if queueIDs != "" {
+		if err := json.Unmarshal([]byte(queueIDs), &s.QueueIDs); err != nil {
+			return nil, fmt.Errorf("decode queues of survey %d: %w", s.ID, err)
+		}
+	}
+	return &s, nil
+}
+
+func scanSurveyRequest(row kbRowScanner) (*models.SurveyRequest, error) {
+	var req models.SurveyRequest
+	var answeredAt sql.NullTime
+	if err := row.Scan(&req.ID, &req.SurveyID, &req.TicketID, &req.TicketNumber, &req.CustomerUserID,
+		&req.SentAt, &req.ExpiresAt, &answeredAt); err != nil {
+		return nil, err
+	}
+	if answeredAt.Valid {
+		req.AnsweredAt = &answeredAt.Time
+	}
+	return &req, nil
+}
+
+func scanSurveyAnswer(row kbRowScanner, requestID *int64) (models.SurveyAnswer, error) {
+	var a models.SurveyAnswer
+	var questionType string
+	var rating sql.NullInt64
+	if err := row.Scan(requestID, &a.QuestionID, &questionType, &rating, &a.Answer); err != nil {
+		return a, err
+	}
+	a.QuestionType = models.SurveyQuestionType(questionType)
+	a.Rating = intPtrFromNull(rating)
+	return a, nil
+}
+
+// encodeSurveyJSON returns the questions and queue IDs of a survey as JSON
+// for their TEXT columns.
+func encodeSurveyJSON(s *models.Survey) (string, interface{}, error) {
+	questions, err := json.Marshal(s.Questions)
+	if err != nil {
+		return "", nil, fmt.Errorf("encode survey questions: %w", err)
+	}
+	if len(s.QueueIDs) == 0 {
+		return string(questions), nil, nil
+	}
+	queueIDs, err := json.Marshal(s.QueueIDs)
+	if err != nil {
+		return "", nil, fmt.Errorf("encode survey queues: %w", err)
+	}
+	return string(questions), string(queueIDs), nil
+}
@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/testutil"
+)
+
+func TestSurveyRepository(t *testing.T) {
+	db := testutil.UseMigratedDB(t)
+	_, err := db.Exec(`INSERT INTO users (id, login, pw, first_name, last_name, valid_id, create_time, create_by, change_time, change_by)
+		VALUES (1, 'root@localhost', 'x', 'Admin', 'OTRS', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+	insertArchiveTestTicket(t, db, 1, 4, now.Add(-2*time.Hour)) // closed, customer with an address
+	insertArchiveTestTicket(t, db, 2, 4, now.Add(-2*time.Hour)) // closed, customer without one
+	insertArchiveTestTicket(t, db, 3, 1, now.Add(-2*time.Hour)) // not closed
+	insertArchiveTestTicket(t, db, 4, 4, now.Add(-time.Minute)) // closed too recently
+	_, err = db.Exec("UPDATE ticket SET customer_user_id = 'ann@example.com' WHERE id IN (1, 3, 4)")
+	require.NoError(t, err)
+	_, err = db.Exec("UPDATE ticket SET customer_user_id = 'bob' WHERE id = 2")
+	require.NoError(t, err)
+
+	repo := NewSurveyRepository(db)
+	survey := &models.Survey{
+		Name: "After close",
+		Questions: []models.SurveyQuestion{
+			{ID: "overall", Type: models.SurveyQuestionRating, Label: "How satisfied are you?", Required: true},
+			{ID: "comment", Type: models.SurveyQuestionText, Label: "Anything else?"},
+		},
+		QueueIDs:      []int{1},
+		LinkValidDays: 14, EmailSubject: "How did we do?", EmailBody: "{{link}}", ValidID: 1,
+		CreateTime: now.AddDate(0, 0, -1), CreateBy: 1, ChangeTime: now, ChangeBy: 1,
+	}
+	require.NoError(t, repo.Create(ctx, survey))
+	require.NotZero(t, survey.ID)
+	exists, err := repo.NameExists(ctx, "After close", 0)
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	got, err := repo.Get(ctx, survey.ID)
+	require.NoError(t, err)
+	require.NotNil(t, got)
+	assert.Equal(t, survey.Questions, got.Questions)
+	assert.Equal(t, []int{1}, got.QueueIDs)
+
+	candidates, err := repo.Candidates(ctx, got.QueueIDs, survey.CreateTime, now.Add(-time.Hour), 10)
+	require.NoError(t, err)
+	require.Len(t, candidates, 1)
+	assert.Equal(t, int64(1), candidates[0].TicketID)
+	assert.Equal(t, "ann@example.com", candidates[0].Email)
+	candidates, err = repo.Candidates(ctx, []int{2}, survey.CreateTime, now.Add(-time.Hour), 10)
+	require.NoError(t, err)
+	assert.Empty(t, candidates)
+
+	req := &models.SurveyRequest{SurveyID: survey.ID, TicketID: 1, SentAt: now, ExpiresAt: now.AddDate(0, 0, 14)}
+	require.NoError(t, repo.CreateRequest(ctx, req, "hash-1"))
+	candidates, err = repo.Candidates(ctx, nil, survey.CreateTime, now.Add(-time.Hour), 10)
+	require.NoError(t, err)
+	assert.Empty(t, candidates, "surveyed tickets are no candidates")
+	sent, err := repo.HasRequests(ctx, survey.ID)
+	require.NoError(t, err)
+	assert.True(t, sent)
+
+	found, err := repo.RequestByTokenHash(ctx, "hash-1")
+	require.NoError(t, err)
+	require.NotNil(t, found)
+	assert.Equal(t, "ann@example.com", found.CustomerUserID)
+	assert.Nil(t, found.AnsweredAt)
+	found, err = repo.RequestByTokenHash(ctx, "unknown")
+	require.NoError(t, err)
+	assert.Nil(t, found)
+
+	four := 4
+	answers := []models.SurveyAnswer{
+		{QuestionID: "overall", QuestionType: models.SurveyQuestionRating, Rating: &four},
+		{QuestionID: "comment", QuestionType: models.SurveyQuestionText, Answer: "Quick fix, thanks"},
+	}
+	require.NoError(t, repo.SaveAnswers(ctx, req.ID, answers, now))
+	assert.ErrorIs(t, repo.SaveAnswers(ctx, req.ID, answers, now), ErrSurveyAnswered)
+
+	responses, err := repo.ListResponses(ctx, survey.ID, 50, 0)
+	require.NoError(t, err)
+	require.Len(t, responses, 1)
+	assert.Equal(t, "20250101000001", responses[0].TicketNumber)
+	assert.Equal(t, answers, responses[0].Answers)
+
+	total, answered, err := repo.CountRequests(ctx, 0, now.Add(-time.Hour), now.Add(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, 1, total)
+	assert.Equal(t, 1, answered)
+	all, err := repo.Answers(ctx, survey.ID, now.Add(-time.Hour), now.Add(time.Hour))
+	require.NoError(t, err)
+	assert.Len(t, all, 2)
+
+	// The report builder sees one row per answered survey with its mean rating
+	tickets, err := NewReportRepository(db).Tickets(ctx, models.ReportMetricAvgSatisfaction, models.ReportFilters{},
+		now.Add(-time.Hour), now.Add(time.Hour))
+	require.NoError(t, err)
+	require.Len(t, tickets, 1)
+	assert.Equal(t, 4.0, tickets[0].Rating)
+
+	deleted, err := repo.Delete(ctx, 999)
+	require.NoError(t, err)
+	assert.False(t, deleted)
+}
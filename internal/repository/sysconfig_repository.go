@@ -0,0 +1,255 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/goatkit/goatflow/internal/database"
+)
+
+// SysconfigRecord is a row of sysconfig_default with its global override
+// from sysconfig_modified, if any.
+type SysconfigRecord struct {
+	DefaultID   int
+	Name        string
+	Description string
+	Navigation  string
+	Invisible   bool
+	ReadOnly    bool
+	Required    bool
+	// Schema is the JSON type information (xml_content_parsed).
+	Schema   string
+	Default  string
+	Modified *string
+	// ChangeTime and ChangeBy describe the override; they are zero when
+	// there is none.
+	ChangeTime time.Time
+	ChangeBy   int
+}
+
+// SysconfigChange sets the global override of a setting, or removes it
+// when Value is nil.
+type SysconfigChange struct {
+	DefaultID int
+	Name      string
+	Value     *string
+}
+
+// SysconfigDeploymentRecord is a row of sysconfig_deployment.
+type SysconfigDeploymentRecord struct {
+	ID         int
+	Comments   string
+	Snapshot   []byte
+	CreateTime time.Time
+	CreateBy   int
+}
+
+// SysconfigRepository reads and writes system configuration settings and
+// deployments.
+type SysconfigRepository struct {
+	db *sql.DB
+}
+
+// NewSysconfigRepository creates a new sysconfig repository.
+func NewSysconfigRepository(db *sql.DB) *SysconfigRepository {
+	return &SysconfigRepository{db: db}
+}
+
+// List returns every valid setting ordered by navigation and name. Per-user
+// overrides (sysconfig_modified.user_id set) are ignored.
+func (r *SysconfigRepository) List(ctx context.Context) ([]*SysconfigRecord, error) {
+	rows, err := r.db.QueryContext(ctx, database.ConvertPlaceholders(`
+		SELECT d.id, d.name, d.description, d.navigation, d.is_invisible, d.is_readonly, d.is_required,
+		       d.xml_content_parsed, d.effective_value, m.effective_value, m.change_time, m.change_by
+		FROM sysconfig_default d
+		LEFT JOIN sysconfig_modified m ON m.name = d.name AND m.user_id IS NULL AND m.is_valid = 1
+		WHERE d.is_valid = 1
+		ORDER BY d.navigation, d.name, m.change_time DESC`))
+	if err != nil {
+		return nil, fmt.Errorf("query sysconfig: %w", err)
+	}
+	defer rows.Close()
+
+	var records []*SysconfigRecord
+	seen := map[string]bool{}
+	for rows.Next() {
+		var rec SysconfigRecord
+		var invisible, readOnly, required int
+		var modified sql.NullString
+		var changeTime sql.NullTime
+		var changeBy sql.NullInt64
+		if err := rows.Scan(&rec.DefaultID, &rec.Name, &rec.Description, &rec.Navigation,
+			&invisible, &readOnly, &required, &rec.Schema, &rec.Default,
+			&modified, &changeTime, &changeBy); err != nil {
+			return nil, fmt.Errorf("scan sysconfig: %w", err)
+		}
+		// Duplicate overrides come newest first; the rest are stale.
+		if seen[rec.Name] {
+			continue
+		}
+		seen[rec.Name] = true
+		rec.Invisible, rec.ReadOnly, rec.Required = invisible == 1, readOnly == 1, required == 1
+		if modified.Valid {
+			rec.Modified = &modified.String
+			rec.ChangeTime = changeTime.Time
+			rec.ChangeBy = int(changeBy.Int64)
+		}
+		records = append(records, &rec)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate sysconfig: %w", err)
+	}
+	return records, nil
+}
+
+// Save applies changes in one transaction.
+func (r *SysconfigRepository) Save(ctx context.Context, changes []SysconfigChange, userID int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin sysconfig save: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := saveSysconfigChanges(ctx, tx, changes, userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// Deploy applies changes and stores the snapshot as a new deployment in one
+// transaction, so a rollback either fully happens and is recorded, or not
+// at all. It returns the deployment ID.
+func (r *SysconfigRepository) Deploy(ctx context.Context, changes []SysconfigChange, comments string, snapshot []byte, userID int) (int, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("begin sysconfig deployment: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := saveSysconfigChanges(ctx, tx, changes, userID); err != nil {
+		return 0, err
+	}
+	var commentArg interface{}
+	if comments != "" {
+		commentArg = comments
+	}
+	id, err := database.GetAdapter().InsertWithReturningTx(tx, database.ConvertPlaceholders(`
+		INSERT INTO sysconfig_deployment (comments, user_id, effective_value, create_time, create_by)
+		VALUES (?, ?, ?, ?, ?)
+		RETURNING id`), commentArg, userID, snapshot, time.Now(), userID)
+	if err != nil {
+		return 0, fmt.Errorf("insert sysconfig deployment: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return int(id), nil
+}
+
+func saveSysconfigChanges(ctx context.Context, tx *sql.Tx, changes []SysconfigChange, userID int) error {
+	now := time.Now()
+	for _, ch := range changes {
+		if ch.Value == nil {
+			if _, err := tx.ExecContext(ctx, database.ConvertPlaceholders(`
+				DELETE FROM sysconfig_modified WHERE name = ? AND user_id IS NULL`), ch.Name); err != nil {
+				return fmt.Errorf("reset %s: %w", ch.Name, err)
+			}
+			continue
+		}
+		res, err := tx.ExecContext(ctx, database.ConvertPlaceholders(`
+			UPDATE sysconfig_modified
+			SET effective_value = ?, is_valid = 1, user_modification_active = 1, is_dirty = 0,
+			    reset_to_default = 0, change_time = ?, change_by = ?
+			WHERE name = ? AND user_id IS NULL`), *ch.Value, now, userID, ch.Name)
+		if err != nil {
+			return fmt.Errorf("update %s: %w", ch.Name, err)
+		}
+		if n, err := res.RowsAffected(); err != nil {
+			return err
+		} else if n > 0 {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, database.ConvertPlaceholders(`
+			INSERT INTO sysconfig_modified (sysconfig_default_id, name, user_id, is_valid, user_modification_active,
+				effective_value, is_dirty, reset_to_default, create_time, create_by, change_time, change_by)
+			VALUES (?, ?, NULL, 1, 1, ?, 0, 0, ?, ?, ?, ?)`),
+			ch.DefaultID, ch.Name, *ch.Value, now, userID, now, userID); err != nil {
+			return fmt.Errorf("insert %s: %w", ch.Name, err)
+		}
+	}
+	return nil
+}
+
+// ListDeployments returns the newest deployments first.
+func (r *SysconfigRepository) ListDeployments(ctx context.Context, limit int) ([]*SysconfigDeploymentRecord, error) {
+	rows, err := r.db.QueryContext(ctx, database.ConvertPlaceholders(`
+		SELECT id, comments, effective_value, create_time, create_by
+		FROM sysconfig_deployment
+		ORDER BY id DESC
+		LIMIT ?`), limit)
+	if err != nil {
+		return nil, fmt.Errorf("query sysconfig deployments: %w", err)
+	}
+	defer rows.Close()
+
+	records, err := database.CollectRows(rows, scanSysconfigDeployment)
+	if err != nil {
+		return nil, fmt.Errorf("scan sysconfig deployment: %w", err)
+	}
+	return records, nil
+}
+
+// GetDeployment returns a deployment, or nil when it does not exist.
+func (r *SysconfigRepository) GetDeployment(ctx context.Context, id int) (*SysconfigDeploymentRecord, error) {
+	rows, err := r.db.QueryContext(ctx, database.ConvertPlaceholders(`
+		SELECT id, comments, effective_value, create_time, create_by
+		FROM sysconfig_deployment
+		WHERE id = ?`), id)
+	if err != nil {
+		return nil, fmt.Errorf("query sysconfig deployment: %w", err)
+	}
+	defer rows.Close()
+
+	records, err := database.CollectRows(rows, scanSysconfigDeployment)
+	if err != nil {
+		return nil, fmt.Errorf("scan sysconfig deployment: %w", err)
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+	return records[0], nil
+}
+
+// PreviousDeployment returns the deployment made before id, or nil when id
+// is the first.
+func (r *SysconfigRepository) PreviousDeployment(ctx context.Context, id int) (*SysconfigDeploymentRecord, error) {
+	rows, err := r.db.QueryContext(ctx, database.ConvertPlaceholders(`
+		SELECT id, comments, effective_value, create_time, create_by
+		FROM sysconfig_deployment
+		WHERE id < ?
+		ORDER BY id DESC
+		LIMIT 1`), id)
+	if err != nil {
+		return nil, fmt.Errorf("query sysconfig deployment: %w", err)
+	}
+	defer rows.Close()
+
+	records, err := database.CollectRows(rows, scanSysconfigDeployment)
+	if err != nil {
+		return nil, fmt.Errorf("scan sysconfig deployment: %w", err)
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+	return records[0], nil
+}
+
+func scanSysconfigDeployment(rows *sql.Rows) (*SysconfigDeploymentRecord, error) {
+	var rec SysconfigDeploymentRecord
+	var comments sql.NullString
+	err := rows.Scan(&rec.ID, &comments, &rec.Snapshot, &rec.CreateTime, &rec.CreateBy)
+	rec.Comments = comments.String
+	return &rec, err
+}
@@ -0,0 +1,146 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/models"
+)
+
+const systemAnnouncementSelect = `
+	SELECT id, message, audience, severity, start_time, stop_time, valid_id,
+	       create_time, create_by, change_time, change_by
+	FROM system_announcement`
+
+// SystemAnnouncementRepository handles database operations for system
+// announcements.
+type SystemAnnouncementRepository struct {
+	db *sql.DB
+}
+
+// NewSystemAnnouncementRepository creates a new system announcement repository.
+func NewSystemAnnouncementRepository(db *sql.DB) *SystemAnnouncementRepository {
+	return &SystemAnnouncementRepository{db: db}
+}
+
+// List returns every announcement, newest first.
+func (r *SystemAnnouncementRepository) List(ctx context.Context) ([]*models.SystemAnnouncement, error) {
+	return r.query(ctx, systemAnnouncementSelect+" ORDER BY create_time DESC, id DESC")
+}
+
+// ListActive returns the valid announcements shown at now, the most urgent
+// and then the newest first.
+func (r *SystemAnnouncementRepository) ListActive(ctx context.Context, now time.Time) ([]*models.SystemAnnouncement, error) {
+	return r.query(ctx, systemAnnouncementSelect+`
+		WHERE valid_id = 1
+		  AND (start_time IS NULL OR start_time <= ?)
+		  AND (stop_time IS NULL OR stop_time >= ?)
+		ORDER BY CASE severity WHEN 'critical' THEN 0 WHEN 'warning' THEN 1 ELSE 2 END, create_time DESC, id DESC`,
+		now, now)
+}
+
+func (r *SystemAnnouncementRepository) query(ctx context.Context, query string, args ...interface{}) ([]*models.SystemAnnouncement, error) {
+	rows, err := r.db.QueryContext(ctx, database.ConvertPlaceholders(query), args...)
+	if err != nil {
+		return nil, fmt.Errorf("query system announcements: %w", err)
+	}
+	defer rows.Close()
+
+	var announcements []*models.SystemAnnouncement
+	for rows.Next() {
+		a, err := scanSystemAnnouncement(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan system announcement: %w", err)
+		}
+		announcements = append(announcements, a)
+	}
+	return announcements, rows.Err()
+}
+
+// Get returns an announcement by ID, or nil if it does not exist.
+func (r *SystemAnnouncementRepository) Get(ctx context.Context, id int) (*models.SystemAnnouncement, error) {
+	row := r.db.QueryRowContext(ctx, database.ConvertPlaceholders(systemAnnouncementSelect+" WHERE id = ?"), id)
+	a, err := scanSystemAnnouncement(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get system announcement: %w", err)
+	}
+	return a, nil
+}
+
+// Create inserts an announcement and returns its ID.
+func (r *SystemAnnouncementRepository) Create(ctx context.Context, a *models.SystemAnnouncement, userID int) (int, error) {
+	now := time.Now()
+	id, err := database.GetAdapter().InsertWithReturning(r.db, database.ConvertPlaceholders(`
+		INSERT INTO system_announcement (message, audience, severity, start_time, stop_time, valid_id,
+			create_time, create_by, change_time, change_by)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id`),
+		a.Message, a.Audience, a.Severity, nullableTime(a.StartTime), nullableTime(a.StopTime), a.ValidID,
+		now, userID, now, userID)
+	if err != nil {
+		return 0, fmt.Errorf("insert system announcement: %w", err)
+	}
+	return int(id), nil
+}
+
+// Update stores changes to an announcement.
+func (r *SystemAnnouncementRepository) Update(ctx context.Context, a *models.SystemAnnouncement, userID int) error {
+	result, err := r.db.ExecContext(ctx, database.ConvertPlaceholders(`
+		UPDATE system_announcement
+		SET message = ?, audience = ?, severity = ?, start_time = ?, stop_time = ?, valid_id = ?,
+		    change_time = ?, change_by = ?
+		WHERE id = ?
+	`), a.Message, a.Audience, a.Severity, nullableTime(a.StartTime), nullableTime(a.StopTime), a.ValidID,
+		time.Now(), userID, a.ID)
+	if err != nil {
+		return fmt.Errorf("update system announcement: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// Delete removes an announcement.
+func (r *SystemAnnouncementRepository) Delete(ctx context.Context, id int) error {
+	result, err := r.db.ExecContext(ctx, database.ConvertPlaceholders(
+		"DELETE FROM system_announcement WHERE id = ?"), id)
+	if err != nil {
+		return fmt.Errorf("delete system announcement: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func nullableTime(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return *t
+}
+
+func scanSystemAnnouncement(row kbRowScanner) (*models.SystemAnnouncement, error) {
+	var a models.SystemAnnouncement
+	var start, stop sql.NullTime
+	if err := row.Scan(&a.ID, &a.Message, &a.Audience, &a.Severity, &start, &stop, &a.ValidID,
+		&a.CreateTime, &a.CreateBy, &a.ChangeTime, &a.ChangeBy); err != nil {
+		return nil, err
+	}
+	if start.Valid {
+		t := start.Time
+		a.StartTime = &t
+	}
+	if stop.Valid {
+		t := stop.Time
+		a.StopTime = &t
+	}
+	return &a, nil
+}
@@ -0,0 +1,87 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/models"
+)
+
+// TelemetryRepository stores the daily usage counted by opt-in telemetry.
+type TelemetryRepository struct {
+	db *sql.DB
+}
+
+// NewTelemetryRepository creates a new telemetry repository.
+func NewTelemetryRepository(db *sql.DB) *TelemetryRepository {
+	return &TelemetryRepository{db: db}
+}
+
+// Add adds usage to the stored counts of its day, route or function.
+func (r *TelemetryRepository) Add(ctx context.Context, usage []models.TelemetryUsage) error {
+	if len(usage) == 0 {
+		return nil
+	}
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin telemetry transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for _, u := range usage {
+		res, err := tx.ExecContext(ctx, database.ConvertPlaceholders(`
+			UPDATE telemetry_usage_daily
+			SET calls = calls + ?, errors = errors + ?, duration_ms = duration_ms + ?
+			WHERE stat_date = ? AND kind = ? AND name = ?`),
+			u.Calls, u.Errors, u.DurationMs, u.Date, u.Kind, u.Name)
+		if err != nil {
+			return fmt.Errorf("update telemetry usage: %w", err)
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, database.ConvertPlaceholders(`
+			INSERT INTO telemetry_usage_daily (stat_date, kind, name, calls, errors, duration_ms)
+			VALUES (?, ?, ?, ?, ?, ?)`),
+			u.Date, u.Kind, u.Name, u.Calls, u.Errors, u.DurationMs); err != nil {
+			return fmt.Errorf("insert telemetry usage: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// Range returns the usage of the days in [from, to], ordered by day.
+func (r *TelemetryRepository) Range(ctx context.Context, from, to time.Time) ([]models.TelemetryUsage, error) {
+	rows, err := r.db.QueryContext(ctx, database.ConvertPlaceholders(`
+		SELECT stat_date, kind, name, calls, errors, duration_ms
+		FROM telemetry_usage_daily
+		WHERE stat_date >= ? AND stat_date < ?
+		ORDER BY stat_date, kind, name`), from, to.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, fmt.Errorf("query telemetry usage: %w", err)
+	}
+	defer rows.Close()
+
+	usage := make([]models.TelemetryUsage, 0)
+	for rows.Next() {
+		var u models.TelemetryUsage
+		if err := rows.Scan(&u.Date, &u.Kind, &u.Name, &u.Calls, &u.Errors, &u.DurationMs); err != nil {
+			return nil, fmt.Errorf("scan telemetry usage: %w", err)
+		}
+		usage = append(usage, u)
+	}
+	return usage, rows.Err()
+}
+
+// DeleteBefore removes the usage of days before the given date.
+func (r *TelemetryRepository) DeleteBefore(ctx context.Context, date time.Time) (int64, error) {
+	res, err := r.db.ExecContext(ctx, database.ConvertPlaceholders(
+		"DELETE FROM telemetry_usage_daily WHERE stat_date < ?"), date)
+	if err != nil {
+		return 0, fmt.Errorf("delete telemetry usage: %w", err)
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,541 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/models"
+)
+
+// Errors returned when a ticket is not in the archive state an operation
+// expects.
+var (
+	ErrTicketAlreadyArchived = errors.New("ticket is already archived")
+	ErrTicketNotArchived     = errors.New("ticket is not archived")
+)
+
+// archivedArticleTables are the article content tables moved to their
+// <name>_archive copy, with the columns they have in common.
+var archivedArticleTables = []struct {
+	name    string
+	columns string
+}{
+	{"article_data_mime", `id, article_id, a_from, a_reply_to, a_to, a_cc, a_bcc, a_subject, a_message_id,
+		a_message_id_md5, a_in_reply_to, a_references, a_content_type, a_body, incoming_time, content_path,
+		create_time, create_by, change_time, change_by`},
+	{"article_data_mime_attachment", `id, article_id, filename, content_size, content_type, content_id,
+		content_alternative, disposition, content, create_time, create_by, change_time, change_by`},
+	{"article_data_mime_plain", `id, article_id, body, create_time, create_by, change_time, change_by`},
+}
+
+// ticketArticles selects the articles of the ticket given as argument.
+const ticketArticles = "article_id IN (SELECT id FROM article WHERE ticket_id = ?)"
+
+// purgeStatements delete everything stored for a ticket, children first.
+// Each takes the ticket ID as its only argument.
+var purgeStatements = []string{
+	"DELETE FROM article_data_mime WHERE " + ticketArticles,
+	"DELETE FROM article_data_mime_attachment WHERE " + ticketArticles,
+	"DELETE FROM article_data_mime_plain WHERE " + ticketArticles,
+	"DELETE FROM article_data_mime_archive WHERE " + ticketArticles,
+	"DELETE FROM article_data_mime_attachment_archive WHERE " + ticketArticles,
+	"DELETE FROM article_data_mime_plain_archive WHERE " + ticketArticles,
+	"DELETE FROM article_data_mime_send_error WHERE " + ticketArticles,
+	"DELETE FROM article_data_otrs_chat WHERE " + ticketArticles,
+	"DELETE FROM article_flag WHERE " + ticketArticles,
+	"DELETE FROM article_visible_text WHERE " + ticketArticles,
+	"DELETE FROM mail_queue WHERE " + ticketArticles,
+	`DELETE FROM dynamic_field_value WHERE object_id IN (SELECT id FROM article WHERE ticket_id = ?)
+		AND field_id IN (SELECT id FROM dynamic_field WHERE object_type = 'Article')`,
+	"DELETE FROM article_search_index WHERE ticket_id = ?",
+	"DELETE FROM mention WHERE ticket_id = ?",
+	"DELETE FROM time_accounting WHERE ticket_id = ?",
+	"DELETE FROM article WHERE ticket_id = ?",
+	`DELETE FROM dynamic_field_value WHERE object_id = ?
+		AND field_id IN (SELECT id FROM dynamic_field WHERE object_type = 'Ticket')`,
+	"DELETE FROM calendar_appointment_ticket WHERE ticket_id = ?",
+	"DELETE FROM ticket_flag WHERE ticket_id = ?",
+	"DELETE FROM ticket_history WHERE ticket_id = ?",
+	"DELETE FROM ticket_index WHERE ticket_id = ?",
+	"DELETE FROM ticket_lock_index WHERE ticket_id = ?",
+	"DELETE FROM ticket_watcher WHERE ticket_id = ?",
+	"DELETE FROM ticket_approval_request WHERE ticket_id = ?",
+	"DELETE FROM ticket_change_ci WHERE ticket_id = ?",
+	"DELETE FROM ticket_change_service WHERE ticket_id = ?",
+	"DELETE FROM ticket_change_plan WHERE ticket_id = ?",
+	"DELETE FROM ticket_archive WHERE ticket_id = ?",
+}
+
+const archivePolicySelect = `
+	SELECT id, name, state_types, queue_id, archive_after_days, move_articles, purge_after_days, valid_id,
+	       create_time, create_by, change_time, change_by
+	FROM archive_policy`
+
+const archiveRunSelect = `
+	SELECT id, policy_id, dry_run, status, tickets_archived, articles_moved, tickets_purged, error_message,
+	       started_at, finished_at, create_by
+	FROM archive_run`
+
+// TicketArchiveRepository handles archive policies, archive runs and moving
+// tickets in and out of the archive.
+type TicketArchiveRepository struct {
+	db      *sql.DB
+	tickets *TicketRepository
+}
+
+// NewTicketArchiveRepository creates a new ticket archive repository.
+func NewTicketArchiveRepository(db *sql.DB) *TicketArchiveRepository {
+	return &TicketArchiveRepository{db: db, tickets: NewTicketRepository(db)}
+}
+
+// ListPolicies returns every archive policy by name.
+func (r *TicketArchiveRepository) ListPolicies(ctx context.Context) ([]*models.ArchivePolicy, error) {
+	rows, err := r.db.QueryContext(ctx, database.ConvertPlaceholders(archivePolicySelect+" ORDER BY name"))
+	if err != nil {
+		return nil, fmt.Errorf("query archive policies: %w", err)
+	}
+	defer rows.Close()
+
+	var policies []*models.ArchivePolicy
+	for rows.Next() {
+		p, err := scanArchivePolicy(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan archive policy: %w", err)
+		}
+		policies = append(policies, p)
+	}
+	return policies, rows.Err()
+}
+
+// GetPolicy returns an archive policy by ID, or nil if it does not exist.
+func (r *TicketArchiveRepository) GetPolicy(ctx context.Context, id int) (*models.ArchivePolicy, error) {
+	row := r.db.QueryRowContext(ctx, database.ConvertPlaceholders(archivePolicySelect+" WHERE id = ?"), id)
+	p, err := scanArchivePolicy(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get archive policy: %w", err)
+	}
+	return p, nil
+}
+
+// CreatePolicy inserts an archive policy and returns its ID.
+func (r *TicketArchiveRepository) CreatePolicy(ctx context.Context, p *models.ArchivePolicy, userID int) (int, error) {
+	now := time.Now()
+	id, err := database.GetAdapter().InsertWithReturning(r.db, database.ConvertPlaceholders(`
+		INSERT INTO archive_policy (name, state_types, queue_id, archive_after_days, move_articles, purge_after_days,
+			valid_id, create_time, create_by, change_time, change_by)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id`),
+		p.Name, p.StateTypesString(), nullableInt(p.QueueID), p.ArchiveAfterDays, boolToSmallint(p.MoveArticles),
+		p.PurgeAfterDays, p.ValidID, now, userID, now, userID)
+	if err != nil {
+		return 0, fmt.Errorf("insert archive policy: %w", err)
+	}
+	return int(id), nil
+}
+
+// UpdatePolicy stores changes to an archive policy.
+func (r *TicketArchiveRepository) UpdatePolicy(ctx context.Context, p *models.ArchivePolicy, userID int) error {
+	result, err := r.db.ExecContext(ctx, database.ConvertPlaceholders(`
+		UPDATE archive_policy
+		SET name = ?, state_types = ?, queue_id = ?, archive_after_days = ?, move_articles = ?,
+		    purge_after_days = ?, valid_id = ?, change_time = ?, change_by = ?
+		WHERE id = ?
+	`), p.Name, p.StateTypesString(), nullableInt(p.QueueID), p.ArchiveAfterDays, boolToSmallint(p.MoveArticles),
+		p.PurgeAfterDays, p.ValidID, time.Now(), userID, p.ID)
+	if err != nil {
+		return fmt.Errorf("update archive policy: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// DeletePolicy removes an archive policy. Tickets it archived stay
+// archived.
+func (r *TicketArchiveRepository) DeletePolicy(ctx context.Context, id int) error {
+	result, err := r.db.ExecContext(ctx, database.ConvertPlaceholders(
+		"DELETE FROM archive_policy WHERE id = ?"), id)
+	if err != nil {
+		return fmt.Errorf("delete archive policy: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// ArchiveCandidates returns up to limit unarchived tickets matched by the
+// policy at now, the longest unchanged first.
+func (r *TicketArchiveRepository) ArchiveCandidates(ctx context.Context, p *models.ArchivePolicy, now time.Time, limit int) ([]int, error) {
+	if len(p.StateTypes) == 0 {
+		return nil, nil
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(p.StateTypes)), ", ")
+	query := `
+		SELECT t.id
+		FROM ticket t
+		JOIN ticket_state s ON s.id = t.ticket_state_id
+		JOIN ticket_state_type st ON st.id = s.type_id
+		WHERE t.archive_flag = 0
+		  AND st.name IN (` + placeholders + `)
+		  AND t.change_time <= ?`
+	args := make([]interface{}, 0, len(p.StateTypes)+3)
+	for _, t := range p.StateTypes {
+		args = append(args, t)
+	}
+	args = append(args, now.AddDate(0, 0, -p.ArchiveAfterDays))
+	if p.QueueID != nil {
+		query += " AND t.queue_id = ?"
+		args = append(args, *p.QueueID)
+	}
+	query += " ORDER BY t.change_time, t.id LIMIT ?"
+	args = append(args, limit)
+
+	return r.queryIDs(ctx, query, args...)
+}
+
+// PurgeCandidates returns up to limit tickets archived by the policy more
+// than its PurgeAfterDays before now.
+func (r *TicketArchiveRepository) PurgeCandidates(ctx context.Context, p *models.ArchivePolicy, now time.Time, limit int) ([]int, error) {
+	if p.PurgeAfterDays <= 0 {
+		return nil, nil
+	}
+	return r.queryIDs(ctx, `
+		SELECT ticket_id FROM ticket_archive
+		WHERE policy_id = ? AND archived_at <= ?
+		ORDER BY archived_at, ticket_id LIMIT ?`,
+		p.ID, now.AddDate(0, 0, -p.PurgeAfterDays), limit)
+}
+
+func (r *TicketArchiveRepository) queryIDs(ctx context.Context, query string, args ...interface{}) ([]int, error) {
+	rows, err := r.db.QueryContext(ctx, database.ConvertPlaceholders(query), args...)
+	if err != nil {
+		return nil, fmt.Errorf("query tickets: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("scan ticket id: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// ArchiveTicket sets the ticket's archive flag and, with moveArticles,
+// moves its article content to the archive tables, all in one transaction.
+// It returns the number of articles whose content was moved.
+func (r *TicketArchiveRepository) ArchiveTicket(ctx context.Context, ticketID int, moveArticles bool, policyID, runID *int, userID int) (int, error) {
+	historyTypeID, err := r.archiveHistoryType(ctx)
+	if err != nil {
+		return 0, err
+	}
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("begin archive: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := setArchiveFlag(ctx, tx, ticketID, true, historyTypeID, userID); err != nil {
+		return 0, err
+	}
+
+	moved := 0
+	if moveArticles {
+		if moved, err = moveArticleContent(ctx, tx, ticketID, true); err != nil {
+			return 0, err
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, database.ConvertPlaceholders(`
+		INSERT INTO ticket_archive (ticket_id, policy_id, run_id, articles_moved, archived_at, archived_by)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`), ticketID, nullableInt(policyID), nullableInt(runID), moved, time.Now(), userID); err != nil {
+		return 0, fmt.Errorf("record archived ticket: %w", err)
+	}
+	return moved, tx.Commit()
+}
+
+// RestoreTicket clears the ticket's archive flag and moves any archived
+// article content back. It returns the number of articles restored.
+func (r *TicketArchiveRepository) RestoreTicket(ctx context.Context, ticketID int, userID int) (int, error) {
+	historyTypeID, err := r.archiveHistoryType(ctx)
+	if err != nil {
+		return 0, err
+	}
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("begin restore: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := setArchiveFlag(ctx, tx, ticketID, false, historyTypeID, userID); err != nil {
+		return 0, err
+	}
+	moved, err := moveArticleContent(ctx, tx, ticketID, false)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := tx.ExecContext(ctx, database.ConvertPlaceholders(
+		"DELETE FROM ticket_archive WHERE ticket_id = ?"), ticketID); err != nil {
+		return 0, fmt.Errorf("remove archive record: %w", err)
+	}
+	return moved, tx.Commit()
+}
+
+// PurgeTicket deletes an archived ticket with its articles, history and
+// everything else stored for it. There is no undo.
+func (r *TicketArchiveRepository) PurgeTicket(ctx context.Context, ticketID int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin purge: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var archived int
+	err = tx.QueryRowContext(ctx, database.ConvertPlaceholders(
+		"SELECT archive_flag FROM ticket WHERE id = ?"), ticketID).Scan(&archived)
+	if err != nil {
+		return err
+	}
+	if archived == 0 {
+		return ErrTicketNotArchived
+	}
+
+	for _, stmt := range purgeStatements {
+		if _, err := tx.ExecContext(ctx, database.ConvertPlaceholders(stmt), ticketID); err != nil {
+			return fmt.Errorf("purge ticket %d: %w", ticketID, err)
+		}
+	}
+	key := strconv.Itoa(ticketID)
+	if _, err := tx.ExecContext(ctx, database.ConvertPlaceholders(`
+		DELETE FROM link_relation
+		WHERE (source_key = ? AND source_object_id IN (SELECT id FROM link_object WHERE name = 'Ticket'))
+		   OR (target_key = ? AND target_object_id IN (SELECT id FROM link_object WHERE name = 'Ticket'))
+	`), key, key); err != nil {
+		return fmt.Errorf("purge ticket %d links: %w", ticketID, err)
+	}
+	if _, err := tx.ExecContext(ctx, database.ConvertPlaceholders(
+		"DELETE FROM ticket WHERE id = ?"), ticketID); err != nil {
+		return fmt.Errorf("purge ticket %d: %w", ticketID, err)
+	}
+	return tx.Commit()
+}
+
+// archiveHistoryType returns the ID of the ArchiveFlagUpdate history type.
+// It is looked up before a transaction starts since it may be created.
+func (r *TicketArchiveRepository) archiveHistoryType(ctx context.Context) (int, error) {
+	id, err := r.tickets.getHistoryTypeID(ctx, "ArchiveFlagUpdate")
+	if err != nil {
+		return 0, fmt.Errorf("archive history type: %w", err)
+	}
+	return id, nil
+}
+
+// setArchiveFlag flips the archive flag and records it in the ticket
+// history the way OTRS does.
+func setArchiveFlag(ctx context.Context, tx *sql.Tx, ticketID int, archived bool, historyTypeID, userID int) error {
+	from, to := 0, 1
+	if !archived {
+		from, to = 1, 0
+	}
+	now := time.Now()
+	result, err := tx.ExecContext(ctx, database.ConvertPlaceholders(`
+		UPDATE ticket SET archive_flag = ?, change_time = ?, change_by = ?
+		WHERE id = ? AND archive_flag = ?
+	`), to, now, userID, ticketID, from)
+	if err != nil {
+		return fmt.Errorf("set archive flag: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		var current int
+		err := tx.QueryRowContext(ctx, database.ConvertPlaceholders(
+			"SELECT archive_flag FROM ticket WHERE id = ?"), ticketID).Scan(&current)
+		switch {
+		case err != nil:
+			return err
+		case archived:
+			return ErrTicketAlreadyArchived
+		default:
+			return ErrTicketNotArchived
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, database.ConvertPlaceholders(fmt.Sprintf(`
+		INSERT INTO ticket_history (name, history_type_id, ticket_id, %[1]s, queue_id, owner_id,
+			priority_id, state_id, create_time, create_by, change_time, change_by)
+		SELECT ?, ?, id, COALESCE(%[1]s, 0), queue_id, user_id, ticket_priority_id, ticket_state_id, ?, ?, ?, ?
+		FROM ticket WHERE id = ?
+	`, database.TicketTypeColumn())), fmt.Sprintf("%%%%%d", to), historyTypeID, now, userID, now, userID, ticketID); err != nil {
+		return fmt.Errorf("record archive history: %w", err)
+	}
+	return nil
+}
+
+// moveArticleContent moves the ticket's article content into the archive
+// tables, or back out of them, and returns the number of articles moved.
+func moveArticleContent(ctx context.Context, tx *sql.Tx, ticketID int, archive bool) (int, error) {
+	moved := 0
+	for i, table := range archivedArticleTables {
+		from, to := table.name, table.name+"_archive"
+		if !archive {
+			from, to = to, from
+		}
+		if _, err := tx.ExecContext(ctx, database.ConvertPlaceholders(fmt.Sprintf(
+			"INSERT INTO %s (%s) SELECT %s FROM %s WHERE %s", to, table.columns, table.columns, from, ticketArticles)),
+			ticketID); err != nil {
+			return 0, fmt.Errorf("copy %s: %w", from, err)
+		}
+		result, err := tx.ExecContext(ctx, database.ConvertPlaceholders(fmt.Sprintf(
+			"DELETE FROM %s WHERE %s", from, ticketArticles)), ticketID)
+		if err != nil {
+			return 0, fmt.Errorf("clear %s: %w", from, err)
+		}
+		// Every article has exactly one article_data_mime row
+		if i == 0 {
+			n, _ := result.RowsAffected()
+			moved = int(n)
+		}
+	}
+	return moved, nil
+}
+
+// ListArchived returns archived tickets, the most recently archived first.
+func (r *TicketArchiveRepository) ListArchived(ctx context.Context, limit, offset int) ([]*models.TicketArchive, error) {
+	rows, err := r.db.QueryContext(ctx, database.ConvertPlaceholders(`
+		SELECT a.ticket_id, t.tn, t.title, a.policy_id, a.run_id, a.articles_moved, a.archived_at, a.archived_by
+		FROM ticket_archive a
+		JOIN ticket t ON t.id = a.ticket_id
+		ORDER BY a.archived_at DESC, a.ticket_id DESC
+		LIMIT ? OFFSET ?
+	`), limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("query archived tickets: %w", err)
+	}
+	defer rows.Close()
+
+	var archived []*models.TicketArchive
+	for rows.Next() {
+		var a models.TicketArchive
+		var title sql.NullString
+		var policyID, runID sql.NullInt64
+		if err := rows.Scan(&a.TicketID, &a.TicketNumber, &title, &policyID, &runID, &a.ArticlesMoved,
+			&a.ArchivedAt, &a.ArchivedBy); err != nil {
+			return nil, fmt.Errorf("scan archived ticket: %w", err)
+		}
+		a.Title = title.String
+		a.PolicyID = intPtrFromNull(policyID)
+		a.RunID = intPtrFromNull(runID)
+		archived = append(archived, &a)
+	}
+	return archived, rows.Err()
+}
+
+// CreateRun records the start of an archive run and returns its ID.
+func (r *TicketArchiveRepository) CreateRun(ctx context.Context, run *models.ArchiveRun) (int, error) {
+	id, err := database.GetAdapter().InsertWithReturning(r.db, database.ConvertPlaceholders(`
+		INSERT INTO archive_run (policy_id, dry_run, status, started_at, create_by)
+		VALUES (?, ?, ?, ?, ?)
+		RETURNING id`),
+		nullableInt(run.PolicyID), boolToSmallint(run.DryRun), run.Status, run.StartedAt, run.CreateBy)
+	if err != nil {
+		return 0, fmt.Errorf("insert archive run: %w", err)
+	}
+	return int(id), nil
+}
+
+// FinishRun stores the outcome of an archive run.
+func (r *TicketArchiveRepository) FinishRun(ctx context.Context, run *models.ArchiveRun) error {
+	var errMsg interface{}
+	if run.Error != "" {
+		msg := run.Error
+		if len(msg) > 1000 {
+			msg = msg[:1000]
+		}
+		errMsg = msg
+	}
+	_, err := r.db.ExecContext(ctx, database.ConvertPlaceholders(`
+		UPDATE archive_run
+		SET status = ?, tickets_archived = ?, articles_moved = ?, tickets_purged = ?, error_message = ?, finished_at = ?
+		WHERE id = ?
+	`), run.Status, run.TicketsArchived, run.ArticlesMoved, run.TicketsPurged, errMsg, run.FinishedAt, run.ID)
+	if err != nil {
+		return fmt.Errorf("update archive run: %w", err)
+	}
+	return nil
+}
+
+// ListRuns returns the most recent archive runs, newest first.
+func (r *TicketArchiveRepository) ListRuns(ctx context.Context, limit int) ([]*models.ArchiveRun, error) {
+	rows, err := r.db.QueryContext(ctx, database.ConvertPlaceholders(
+		archiveRunSelect+" ORDER BY started_at DESC, id DESC LIMIT ?"), limit)
+	if err != nil {
+		return nil, fmt.Errorf("query archive runs: %w", err)
+	}
+	defer rows.Close()
+
+	var runs []*models.ArchiveRun
+	for rows.Next() {
+		var run models.ArchiveRun
+		var policyID sql.NullInt64
+		var dryRun int
+		var errMsg sql.NullString
+		var finished sql.NullTime
+		if err := rows.Scan(&run.ID, &policyID, &dryRun, &run.Status, &run.TicketsArchived, &run.ArticlesMoved,
+			&run.TicketsPurged, &errMsg, &run.StartedAt, &finished, &run.CreateBy); err != nil {
+			return nil, fmt.Errorf("scan archive run: %w", err)
+		}
+		run.PolicyID = intPtrFromNull(policyID)
+		run.DryRun = dryRun != 0
+		run.Error = errMsg.String
+		if finished.Valid {
+			t := finished.Time
+			run.FinishedAt = &t
+		}
+		runs = append(runs, &run)
+	}
+	return runs, rows.Err()
+}
+
+func scanArchivePolicy(row kbRowScanner) (*models.ArchivePolicy, error) {
+	var p models.ArchivePolicy
+	var stateTypes string
+	var queueID sql.NullInt64
+	var moveArticles int
+	if err := row.Scan(&p.ID, &p.Name, &stateTypes, &queueID, &p.ArchiveAfterDays, &moveArticles, &p.PurgeAfterDays,
+		&p.ValidID, &p.CreateTime, &p.CreateBy, &p.ChangeTime, &p.ChangeBy); err != nil {
+		return nil, err
+	}
+	p.StateTypes = models.ParseArchiveStateTypes(stateTypes)
+	p.QueueID = intPtrFromNull(queueID)
+	p.MoveArticles = moveArticles != 0
+	return &p, nil
+}
+
+func nullableInt(v *int) interface{} {
+	if v == nil {
+		return nil
+	}
+	return *v
+}
+
+func intPtrFromNull(v sql.NullInt64) *int {
+	if !v.Valid {
+		return nil
+	}
+	n := int(v.Int64)
+	return &n
+}
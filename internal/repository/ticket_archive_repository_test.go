@@ -0,0 +1,172 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/testutil"
+)
+
+func insertArchiveTestTicket(t *testing.T, db *sql.DB, id, stateID int, changed time.Time) {
+	t.Helper()
+	_, err := db.Exec(`INSERT INTO ticket (id, tn, title, queue_id, ticket_lock_id, user_id, responsible_user_id,
+		ticket_priority_id, ticket_state_id, timeout, until_time, escalation_time, escalation_update_time,
+		escalation_response_time, escalation_solution_time, create_time, create_by, change_time, change_by)
+		VALUES (?, ?, 'Printer', 1, 1, 1, 1, 3, ?, 0, 0, 0, 0, 0, 0, ?, 1, ?, 1)`,
+		id, "2025010100000"+string(rune('0'+id)), stateID, changed, changed)
+	require.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO article (id, ticket_id, article_sender_type_id, communication_channel_id,
+		is_visible_for_customer, create_time, create_by, change_time, change_by) VALUES (?, ?, 1, 1, 1, ?, 1, ?, 1)`,
+		id*10, id, changed, changed)
+	require.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO article_data_mime (article_id, a_subject, a_body, incoming_time,
+		create_time, create_by, change_time, change_by) VALUES (?, 'Printer', 'It is on fire', 0, ?, 1, ?, 1)`,
+		id*10, changed, changed)
+	require.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO article_data_mime_attachment (article_id, filename, content, create_time, create_by,
+		change_time, change_by) VALUES (?, 'smoke.jpg', ?, ?, 1, ?, 1)`, id*10, []byte{0xff, 0xd8}, changed, changed)
+	require.NoError(t, err)
+}
+
+func countRows(t *testing.T, db *sql.DB, query string, args ...interface{}) int {
+	t.Helper()
+	var n int
+	require.NoError(t, db.QueryRow(query, args...).Scan(&n))
+	return n
+}
+
+func TestTicketArchiveRepository(t *testing.T) {
+	db := testutil.UseMigratedDB(t)
+	_, err := db.Exec(`INSERT INTO users (id, login, pw, first_name, last_name, valid_id, create_time, create_by, change_time, change_by)
+		VALUES (1, 'root@localhost', 'x', 'Admin', 'OTRS', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	now := time.Now().UTC()
+	old := now.AddDate(0, 0, -120)
+	insertArchiveTestTicket(t, db, 1, 4, old)                   // closed long ago
+	insertArchiveTestTicket(t, db, 2, 2, old)                   // open
+	insertArchiveTestTicket(t, db, 3, 4, now.AddDate(0, 0, -5)) // closed recently
+
+	repo := NewTicketArchiveRepository(db)
+	policy := &models.ArchivePolicy{Name: "Closed tickets", StateTypes: []string{"closed"}, ArchiveAfterDays: 90,
+		MoveArticles: true, PurgeAfterDays: 365, ValidID: 1}
+	policy.ID, err = repo.CreatePolicy(ctx, policy, 1)
+	require.NoError(t, err)
+
+	got, err := repo.GetPolicy(ctx, policy.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"closed"}, got.StateTypes)
+	assert.True(t, got.MoveArticles)
+	assert.Nil(t, got.QueueID)
+
+	ids, err := repo.ArchiveCandidates(ctx, policy, now, 100)
+	require.NoError(t, err)
+	assert.Equal(t, []int{1}, ids)
+
+	moved, err := repo.ArchiveTicket(ctx, 1, true, &policy.ID, nil, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 1, moved)
+	assert.Equal(t, 1, countRows(t, db, "SELECT archive_flag FROM ticket WHERE id = 1"))
+	assert.Zero(t, countRows(t, db, "SELECT COUNT(*) FROM article_data_mime WHERE article_id = 10"))
+	assert.Equal(t, 1, countRows(t, db, "SELECT COUNT(*) FROM article_data_mime_archive WHERE article_id = 10"))
+	assert.Equal(t, 1, countRows(t, db, "SELECT COUNT(*) FROM article_data_mime_attachment_archive WHERE article_id = 10"))
+	assert.Equal(t, 1, countRows(t, db, `SELECT COUNT(*) FROM ticket_history h JOIN ticket_history_type ht
+		ON ht.id = h.history_type_id WHERE h.ticket_id = 1 AND ht.name = 'ArchiveFlagUpdate'`))
+
+	_, err = repo.ArchiveTicket(ctx, 1, true, nil, nil, 1)
+	assert.ErrorIs(t, err, ErrTicketAlreadyArchived)
+	ids, err = repo.ArchiveCandidates(ctx, policy, now, 100)
+	require.NoError(t, err)
+	assert.Empty(t, ids, "archived tickets are no candidates")
+
+	archived, err := repo.ListArchived(ctx, 10, 0)
+	require.NoError(t, err)
+	require.Len(t, archived, 1)
+	assert.Equal(t, 1, archived[0].ArticlesMoved)
+	assert.Equal(t, policy.ID, *archived[0].PolicyID)
+
+	// Restore puts the content back
+	restored, err := repo.RestoreTicket(ctx, 1, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 1, restored)
+	var body string
+	require.NoError(t, db.QueryRow("SELECT a_body FROM article_data_mime WHERE article_id = 10").Scan(&body))
+	assert.Equal(t, "It is on fire", body)
+	assert.Zero(t, countRows(t, db, "SELECT COUNT(*) FROM ticket_archive"))
+	_, err = repo.RestoreTicket(ctx, 1, 1)
+	assert.ErrorIs(t, err, ErrTicketNotArchived)
+
+	// Purging only removes archived tickets
+	assert.ErrorIs(t, repo.PurgeTicket(ctx, 1), ErrTicketNotArchived)
+	_, err = repo.ArchiveTicket(ctx, 1, true, &policy.ID, nil, 1)
+	require.NoError(t, err)
+	ids, err = repo.PurgeCandidates(ctx, policy, now.AddDate(1, 0, 1), 100)
+	require.NoError(t, err)
+	assert.Equal(t, []int{1}, ids)
+	require.NoError(t, repo.PurgeTicket(ctx, 1))
+	assert.Zero(t, countRows(t, db, "SELECT COUNT(*) FROM ticket WHERE id = 1"))
+	assert.Zero(t, countRows(t, db, "SELECT COUNT(*) FROM article WHERE ticket_id = 1"))
+	assert.Zero(t, countRows(t, db, "SELECT COUNT(*) FROM article_data_mime_archive"))
+	assert.Zero(t, countRows(t, db, "SELECT COUNT(*) FROM ticket_history WHERE ticket_id = 1"))
+	assert.Equal(t, 2, countRows(t, db, "SELECT COUNT(*) FROM ticket"))
+
+	run := &models.ArchiveRun{PolicyID: &policy.ID, Status: models.ArchiveRunRunning, StartedAt: now, CreateBy: 1}
+	run.ID, err = repo.CreateRun(ctx, run)
+	require.NoError(t, err)
+	finished := now.Add(time.Second)
+	run.Status, run.TicketsArchived, run.FinishedAt = models.ArchiveRunSuccess, 1, &finished
+	require.NoError(t, repo.FinishRun(ctx, run))
+	runs, err := repo.ListRuns(ctx, 10)
+	require.NoError(t, err)
+	require.Len(t, runs, 1)
+	assert.Equal(t, models.ArchiveRunSuccess, runs[0].Status)
+	assert.Equal(t, 1, runs[0].TicketsArchived)
+	assert.NotNil(t, runs[0].FinishedAt)
+
+	require.NoError(t, repo.DeletePolicy(ctx, policy.ID))
+	assert.ErrorIs(t, repo.DeletePolicy(ctx, policy.ID), sql.ErrNoRows)
+}
+
+func TestTicketArchiveRepository_ArticlesReadableAfterArchiving(t *testing.T) {
+	db := testutil.UseMigratedDB(t)
+	_, err := db.Exec(`INSERT INTO users (id, login, pw, first_name, last_name, valid_id, create_time, create_by, change_time, change_by)
+		VALUES (1, 'root@localhost', 'x', 'Admin', 'OTRS', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`)
+	require.NoError(t, err)
+	insertArchiveTestTicket(t, db, 1, 4, time.Now().AddDate(0, 0, -120))
+
+	ctx := context.Background()
+	repo := NewTicketArchiveRepository(db)
+	articles := NewArticleRepository(db)
+	readBody := func() string {
+		t.Helper()
+		article, err := articles.GetByID(10)
+		require.NoError(t, err)
+		body, _ := article.Body.(string)
+		return body
+	}
+
+	moved, err := repo.ArchiveTicket(ctx, 1, false, nil, nil, 1)
+	require.NoError(t, err)
+	assert.Zero(t, moved)
+	assert.Equal(t, "It is on fire", readBody(), "the content stays readable without move_articles")
+	list, err := articles.GetByTicketID(1, true)
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, "Printer", list[0].Subject)
+	_, err = repo.RestoreTicket(ctx, 1, 1)
+	require.NoError(t, err)
+
+	_, err = repo.ArchiveTicket(ctx, 1, true, nil, nil, 1)
+	require.NoError(t, err)
+	assert.Empty(t, readBody(), "moved content is not read until the ticket is restored")
+	_, err = repo.RestoreTicket(ctx, 1, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "It is on fire", readBody())
+}
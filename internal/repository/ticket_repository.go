@@ -469,6 +469,11 @@ func (r *TicketRepository) List(req *models.TicketListRequest) (*models.TicketLi
 		args = append(args, *req.PriorityID)
 	}
 
+	if req.TypeID != nil {
+		filters = append(filters, " AND t.type_id = ?")
+		args = append(args, *req.TypeID)
+	}
+
 	if req.CustomerID != nil {
 		filters = append(filters, " AND t.customer_id = ?")
 		args = append(args, *req.CustomerID)
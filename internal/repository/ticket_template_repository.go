@@ -2,17 +2,24 @@ package repository
 
 import (
 	"context"
+	"errors"
 
 	"github.com/goatkit/goatflow/internal/models"
 )
 
+// ErrTicketTemplateNotFound is returned for ticket templates that do not exist.
+var ErrTicketTemplateNotFound = errors.New("ticket template not found")
+
 // TicketTemplateRepository defines the interface for ticket template persistence.
 type TicketTemplateRepository interface {
 	// Template CRUD operations
 	CreateTemplate(ctx context.Context, template *models.TicketTemplate) error
 	GetTemplateByID(ctx context.Context, id uint) (*models.TicketTemplate, error)
+	GetAllTemplates(ctx context.Context) ([]models.TicketTemplate, error)
 	GetActiveTemplates(ctx context.Context) ([]models.TicketTemplate, error)
 	GetTemplatesByCategory(ctx context.Context, category string) ([]models.TicketTemplate, error)
+	GetTemplatesForUser(ctx context.Context, userID int) ([]models.TicketTemplate, error)
+	TemplateNameExists(ctx context.Context, name string, excludeID uint) (bool, error)
 	UpdateTemplate(ctx context.Context, template *models.TicketTemplate) error
 	DeleteTemplate(ctx context.Context, id uint) error
 
@@ -0,0 +1,367 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/models"
+)
+
+const ticketTemplateSelect = `
+	SELECT tt.id, tt.name, COALESCE(tt.description, ''), COALESCE(tt.category, ''), tt.title, tt.body,
+	       tt.content_type, COALESCE(tt.queue_id, 0), COALESCE(tt.priority_id, 0), COALESCE(p.name, ''),
+	       COALESCE(tt.type_id, 0), COALESCE(tt.state_id, 0), COALESCE(tt.service_id, 0),
+	       COALESCE(tt.dynamic_fields, ''), COALESCE(tt.tags, ''), tt.usage_count, tt.valid_id,
+	       tt.create_time, tt.create_by, tt.change_time, tt.change_by
+	FROM ticket_template tt
+	LEFT JOIN ticket_priority p ON p.id = tt.priority_id`
+
+// TicketTemplateSQLRepository stores ticket templates and the groups that
+// may use them in the database.
+type TicketTemplateSQLRepository struct {
+	db *sql.DB
+}
+
+// NewTicketTemplateSQLRepository creates a new ticket template repository.
+func NewTicketTemplateSQLRepository(db *sql.DB) *TicketTemplateSQLRepository {
+	return &TicketTemplateSQLRepository{db: db}
+}
+
+// CreateTemplate inserts a template with its groups, setting its ID.
+func (r *TicketTemplateSQLRepository) CreateTemplate(ctx context.Context, t *models.TicketTemplate) error {
+	dynamicFields, tags, err := encodeTicketTemplateJSON(t)
+	if err != nil {
+		return err
+	}
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin ticket template insert: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck // No-op after commit
+
+	id, err := database.GetAdapter().InsertWithReturningTx(tx, database.ConvertPlaceholders(`
+		INSERT INTO ticket_template (name, description, category, title, body, content_type,
+			queue_id, priority_id, type_id, state_id, service_id, dynamic_fields, tags, usage_count, valid_id,
+			create_time, create_by, change_time, change_by)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, 0, ?, ?, ?, ?, ?)
+		RETURNING id`),
+		t.Name, t.Description, t.Category, t.Subject, t.Body, t.ContentType,
+		nullablePortalProvider(t.QueueID), nullablePortalProvider(t.PriorityID), nullablePortalProvider(t.TypeID),
+		nullablePortalProvider(t.StateID), nullablePortalProvider(t.ServiceID), dynamicFields, tags,
+		ticketTemplateValidID(t.Active), t.CreatedAt, t.CreatedBy, t.UpdatedAt, t.UpdatedBy)
+	if err != nil {
+		return fmt.Errorf("insert ticket template: %w", err)
+	}
+	if err := insertTicketTemplateGroups(ctx, tx, int(id), t.GroupIDs); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit ticket template insert: %w", err)
+	}
+	t.ID = uint(id)
+	return nil
+}
+
+// GetTemplateByID returns a template with its groups.
+func (r *TicketTemplateSQLRepository) GetTemplateByID(ctx context.Context, id uint) (*models.TicketTemplate, error) {
+	t, err := scanTicketTemplate(r.db.QueryRowContext(ctx,
+		database.ConvertPlaceholders(ticketTemplateSelect+" WHERE tt.id = ?"), id))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("%w: %d", ErrTicketTemplateNotFound, id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("query ticket template: %w", err)
+	}
+	list := []models.TicketTemplate{*t}
+	if err := r.loadGroups(ctx, list); err != nil {
+		return nil, err
+	}
+	return &list[0], nil
+}
+
+// GetAllTemplates returns every template ordered by name.
+func (r *TicketTemplateSQLRepository) GetAllTemplates(ctx context.Context) ([]models.TicketTemplate, error) {
+	return r.list(ctx, "1 = 1")
+}
+
+// GetActiveTemplates returns the valid templates ordered by name.
+func (r *TicketTemplateSQLRepository) GetActiveTemplates(ctx context.Context) ([]models.TicketTemplate, error) {
+	return r.list(ctx, "tt.valid_id = 1")
+}
+
+// GetTemplatesByCategory returns the templates of a category ordered by name.
+func (r *TicketTemplateSQLRepository) GetTemplatesByCategory(ctx context.Context, category string) ([]models.TicketTemplate, error) {
+	return r.list(ctx, "tt.category = ?", category)
+}
+
+// GetTemplatesForUser returns the valid templates an agent may use: those
+// without groups and those of a group the agent belongs to, directly or
+// through a role.
+func (r *TicketTemplateSQLRepository) GetTemplatesForUser(ctx context.Context, userID int) ([]models.TicketTemplate, error) {
+	return r.list(ctx, `tt.valid_id = 1 AND (
+		NOT EXISTS (SELECT 1 FROM ticket_template_group g WHERE g.template_id = tt.id)
+		OR EXISTS (
+			SELECT 1 FROM ticket_template_group g
+			WHERE g.template_id = tt.id AND g.group_id IN (
+				SELECT gu.group_id FROM group_user gu WHERE gu.user_id = ?
+				UNION
+				SELECT gr.group_id FROM role_user ru
+				INNER JOIN roles ro ON ro.id = ru.role_id AND ro.valid_id = 1
+				INNER JOIN group_role gr ON gr.role_id = ru.role_id AND gr.permission_value = 1
+				WHERE ru.user_id = ?)))`, userID, userID)
+}
+
+// UpdateTemplate stores changes to a template and replaces its groups.
+func (r *TicketTemplateSQLRepository) UpdateTemplate(ctx context.Context, t *models.TicketTemplate) error {
+	dynamicFields, tags, err := encodeTicketTemplateJSON(t)
+	if err != nil {
+		return err
+	}
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin ticket template update: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck // No-op after commit
+
+	result, err := tx.ExecContext(ctx, database.ConvertPlaceholders(`
+		UPDATE ticket_template
+		SET name = ?, description = ?, category = ?, title = ?, body = ?, content_type = ?,
+		    queue_id = ?, priority_id = ?, type_id = ?, state_id = ?, service_id = ?,
+		    dynamic_fields = ?, tags = ?, valid_id = ?, change_time = ?, change_by = ?
+		WHERE id = ?`),
+		t.Name, t.Description, t.Category, t.Subject, t.Body, t.ContentType,
+		nullablePortalProvider(t.QueueID), nullablePortalProvider(t.PriorityID), nullablePortalProvider(t.TypeID),
+		nullablePortalProvider(t.StateID), nullablePortalProvider(t.ServiceID), dynamicFields, tags,
+		ticketTemplateValidID(t.Active), t.UpdatedAt, t.UpdatedBy, t.ID)
+	if err != nil {
+		return fmt.Errorf("update ticket template: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("%w: %d", ErrTicketTemplateNotFound, t.ID)
+	}
+	if _, err := tx.ExecContext(ctx, database.ConvertPlaceholders(
+		"DELETE FROM ticket_template_group WHERE template_id = ?"), t.ID); err != nil {
+		return fmt.Errorf("clear ticket template groups: %w", err)
+	}
+	if err := insertTicketTemplateGroups(ctx, tx, int(t.ID), t.GroupIDs); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("commit ticket template update: %w", err)
+	}
+	return nil
+}
+
+// DeleteTemplate deletes a template and its groups.
+func (r *TicketTemplateSQLRepository) DeleteTemplate(ctx context.Context, id uint) error {
+	if _, err := r.db.ExecContext(ctx, database.ConvertPlaceholders(
+		"DELETE FROM ticket_template_group WHERE template_id = ?"), id); err != nil {
+		return fmt.Errorf("delete ticket template groups: %w", err)
+	}
+	result, err := r.db.ExecContext(ctx, database.ConvertPlaceholders(
+		"DELETE FROM ticket_template WHERE id = ?"), id)
+	if err != nil {
+		return fmt.Errorf("delete ticket template: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("%w: %d", ErrTicketTemplateNotFound, id)
+	}
+	return nil
+}
+
+// IncrementUsageCount counts one more ticket created from the template.
+func (r *TicketTemplateSQLRepository) IncrementUsageCount(ctx context.Context, templateID uint) error {
+	result, err := r.db.ExecContext(ctx, database.ConvertPlaceholders(
+		"UPDATE ticket_template SET usage_count = usage_count + 1 WHERE id = ?"), templateID)
+	if err != nil {
+		return fmt.Errorf("count ticket template usage: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("%w: %d", ErrTicketTemplateNotFound, templateID)
+	}
+	return nil
+}
+
+// SearchTemplates returns the valid templates whose name, description,
+// title or tags contain the query.
+func (r *TicketTemplateSQLRepository) SearchTemplates(ctx context.Context, query string) ([]models.TicketTemplate, error) {
+	like := "%" + strings.ToLower(query) + "%"
+	return r.list(ctx, `tt.valid_id = 1 AND (LOWER(tt.name) LIKE ? OR LOWER(COALESCE(tt.description, '')) LIKE ?
+		OR LOWER(tt.title) LIKE ? OR LOWER(COALESCE(tt.tags, '')) LIKE ?)`, like, like, like, like)
+}
+
+// GetCategories returns the categories of the valid templates by name.
+func (r *TicketTemplateSQLRepository) GetCategories(ctx context.Context) ([]models.TemplateCategory, error) {
+	rows, err := r.db.QueryContext(ctx, database.ConvertPlaceholders(`
+		SELECT category, COUNT(*) FROM ticket_template
+		WHERE valid_id = 1 AND category IS NOT NULL AND category <> ''
+		GROUP BY category
+		ORDER BY category`))
+	if err != nil {
+		return nil, fmt.Errorf("query ticket template categories: %w", err)
+	}
+	defer rows.Close()
+
+	categories := []models.TemplateCategory{}
+	for rows.Next() {
+		var name string
+		var count int
+		if err := rows.Scan(&name, &count); err != nil {
+			return nil, fmt.Errorf("scan ticket template category: %w", err)
+		}
+		order := len(categories) + 1
+		categories = append(categories, models.TemplateCategory{
+			ID:          order,
+			Name:        name,
+			Description: fmt.Sprintf("%d templates", count),
+			Order:       order,
+			Active:      true,
+		})
+	}
+	return categories, rows.Err()
+}
+
+// TemplateNameExists reports whether another template already uses the name.
+func (r *TicketTemplateSQLRepository) TemplateNameExists(ctx context.Context, name string, excludeID uint) (bool, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx, database.ConvertPlaceholders(
+		"SELECT COUNT(*) FROM ticket_template WHERE name = ? AND id <> ?"), name, excludeID).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("check ticket template name: %w", err)
+	}
+	return count > 0, nil
+}
+
+// list returns the templates matching where, ordered by name, with their
+// groups.
+func (r *TicketTemplateSQLRepository) list(ctx context.Context, where string, args ...interface{}) ([]models.TicketTemplate, error) {
+	rows, err := r.db.QueryContext(ctx, database.ConvertPlaceholders(
+		ticketTemplateSelect+" WHERE "+where+" ORDER BY tt.name"), args...)
+	if err != nil {
+		return nil, fmt.Errorf("query ticket templates: %w", err)
+	}
+	defer rows.Close()
+
+	var templates []models.TicketTemplate
+	for rows.Next() {
+		t, err := scanTicketTemplate(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan ticket template: %w", err)
+		}
+		templates = append(templates, *t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if err := r.loadGroups(ctx, templates); err != nil {
+		return nil, err
+	}
+	return templates, nil
+}
+
+// loadGroups sets the GroupIDs of the templates.
+func (r *TicketTemplateSQLRepository) loadGroups(ctx context.Context, templates []models.TicketTemplate) error {
+	if len(templates) == 0 {
+		return nil
+	}
+	index := make(map[uint]int, len(templates))
+	placeholders := make([]string, len(templates))
+	args := make([]interface{}, len(templates))
+	for i := range templates {
+		index[templates[i].ID] = i
+		placeholders[i] = "?"
+		args[i] = templates[i].ID
+	}
+	rows, err := r.db.QueryContext(ctx, database.ConvertPlaceholders(`
+		SELECT template_id, group_id FROM ticket_template_group
+		WHERE template_id IN (`+strings.Join(placeholders, ", ")+`)
+		ORDER BY template_id, group_id`), args...)
+	if err != nil {
+		return fmt.Errorf("query ticket template groups: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var templateID uint
+		var groupID int
+		if err := rows.Scan(&templateID, &groupID); err != nil {
+			return fmt.Errorf("scan ticket template group: %w", err)
+		}
+		if i, ok := index[templateID]; ok {
+			templates[i].GroupIDs = append(templates[i].GroupIDs, groupID)
+		}
+	}
+	return rows.Err()
+}
+
+func insertTicketTemplateGroups(ctx context.Context, tx *sql.Tx, templateID int, groupIDs []int) error {
+	seen := make(map[int]bool, len(groupIDs))
+	for _, groupID := range groupIDs {
+		if seen[groupID] {
+			continue
+		}
+		seen[groupID] = true
+		if _, err := tx.ExecContext(ctx, database.ConvertPlaceholders(
+			"INSERT INTO ticket_template_group (template_id, group_id) VALUES (?, ?)"), templateID, groupID); err != nil {
+			return fmt.Errorf("insert ticket template group: %w", err)
+		}
+	}
+	return nil
+}
+
+func scanTicketTemplate(row kbRowScanner) (*models.TicketTemplate, error) {
+	var t models.TicketTemplate
+	var dynamicFields, tags string
+	var validID int
+	if err := row.Scan(&t.ID, &t.Name, &t.Description, &t.Category, &t.Subject, &t.Body,
+		&t.ContentType, &t.QueueID, &t.PriorityID, &t.Priority,
+		&t.TypeID, &t.StateID, &t.ServiceID,
+		&dynamicFields, &tags, &t.UsageCount, &validID,
+		&t.CreatedAt, &t.CreatedBy, &t.UpdatedAt, &t.UpdatedBy); err != nil {
+		return nil, err
+	}
+	t.Active = validID == 1
+	if dynamicFields != "" {
+		if err := json.Unmarshal([]byte(dynamicFields), &t.DynamicFields); err != nil {
+			return nil, fmt.Errorf("decode dynamic fields of ticket template %d: %w", t.ID, err)
+		}
+	}
+	if tags != "" {
+		if err := json.Unmarshal([]byte(tags), &t.Tags); err != nil {
+			return nil, fmt.Errorf("decode tags of ticket template %d: %w", t.ID, err)
+		}
+	}
+	return &t, nil
+}
+
+// encodeTicketTemplateJSON returns the dynamic field values and tags of a
+// template as JSON for their TEXT columns, or nil when there are none.
+func encodeTicketTemplateJSON(t *models.TicketTemplate) (interface{}, interface{}, error) {
+	var dynamicFields, tags interface{}
+	if len(t.DynamicFields) > 0 {
+		b, err := json.Marshal(t.DynamicFields)
+		if err != nil {
+			return nil, nil, fmt.Errorf("encode ticket template dynamic fields: %w", err)
+		}
+		dynamicFields = string(b)
+	}
+	if len(t.Tags) > 0 {
+		b, err := json.Marshal(t.Tags)
+		if err != nil {
+			return nil, nil, fmt.Errorf("encode ticket template tags: %w", err)
+		}
+		tags = string(b)
+	}
+	return dynamicFields, tags, nil
+}
+
+func ticketTemplateValidID(active bool) int {
+	if active {
+		return 1
+	}
+	return 2
+}
@@ -0,0 +1,145 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/testutil"
+)
+
+func TestTicketTemplateSQLRepository(t *testing.T) {
+	db := testutil.UseMigratedDB(t)
+	for _, stmt := range []string{
+		`INSERT INTO users (id, login, pw, first_name, last_name, valid_id, create_time, create_by, change_time, change_by)
+			VALUES (1, 'root@localhost', 'x', 'Admin', 'OTRS', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+		`INSERT INTO users (id, login, pw, first_name, last_name, valid_id, create_time, create_by, change_time, change_by)
+			VALUES (2, 'agent', 'x', 'Ann', 'Agent', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+		`INSERT INTO groups (id, name, valid_id, create_time, create_by, change_time, change_by)
+			VALUES (10, 'billing', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+		`INSERT INTO groups (id, name, valid_id, create_time, create_by, change_time, change_by)
+			VALUES (11, 'hardware', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+		`INSERT INTO group_user (user_id, group_id, permission_key, create_time, create_by, change_time, change_by)
+			VALUES (2, 10, 'rw', CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+	} {
+		_, err := db.Exec(stmt)
+		require.NoError(t, err)
+	}
+
+	ctx := context.Background()
+	now := time.Now().UTC().Truncate(time.Second)
+	repo := NewTicketTemplateSQLRepository(db)
+	newTemplate := func(name, category string, groupIDs ...int) *models.TicketTemplate {
+		return &models.TicketTemplate{
+			Name: name, Category: category, Subject: name + " for {{customer}}", Body: "Please help",
+			ContentType: "text/plain", QueueID: 1, PriorityID: 3, Active: true, GroupIDs: groupIDs,
+			CreatedBy: 1, UpdatedBy: 1, CreatedAt: now, UpdatedAt: now,
+		}
+	}
+
+	public := newTemplate("Password reset", "Accounts")
+	public.Tags = []string{"password", "login"}
+	public.DynamicFields = map[string]string{"Channel": "phone"}
+	require.NoError(t, repo.CreateTemplate(ctx, public))
+	require.NotZero(t, public.ID)
+	billing := newTemplate("Refund", "Billing", 10, 10)
+	require.NoError(t, repo.CreateTemplate(ctx, billing))
+	hardware := newTemplate("Broken laptop", "Hardware", 11)
+	require.NoError(t, repo.CreateTemplate(ctx, hardware))
+
+	got, err := repo.GetTemplateByID(ctx, public.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Password reset for {{customer}}", got.Subject)
+	assert.Equal(t, []string{"password", "login"}, got.Tags)
+	assert.Equal(t, map[string]string{"Channel": "phone"}, got.DynamicFields)
+	assert.Equal(t, 3, got.PriorityID)
+	assert.True(t, got.Active)
+	assert.Empty(t, got.GroupIDs)
+
+	got, err = repo.GetTemplateByID(ctx, billing.ID)
+	require.NoError(t, err)
+	assert.Equal(t, []int{10}, got.GroupIDs)
+
+	_, err = repo.GetTemplateByID(ctx, 999)
+	assert.ErrorIs(t, err, ErrTicketTemplateNotFound)
+
+	// The agent sees the public template and the one of their group.
+	visible, err := repo.GetTemplatesForUser(ctx, 2)
+	require.NoError(t, err)
+	require.Len(t, visible, 2)
+	assert.Equal(t, "Password reset", visible[0].Name)
+	assert.Equal(t, "Refund", visible[1].Name)
+
+	// Role groups count as well.
+	for _, stmt := range []string{
+		`INSERT INTO roles (id, name, valid_id, create_time, create_by, change_time, change_by)
+			VALUES (5, 'Technicians', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+		`INSERT INTO role_user (user_id, role_id, create_time, create_by, change_time, change_by)
+			VALUES (2, 5, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+		`INSERT INTO group_role (role_id, group_id, permission_key, permission_value, create_time, create_by, change_time, change_by)
+			VALUES (5, 11, 'rw', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+	} {
+		_, err := db.Exec(stmt)
+		require.NoError(t, err)
+	}
+	visible, err = repo.GetTemplatesForUser(ctx, 2)
+	require.NoError(t, err)
+	assert.Len(t, visible, 3)
+
+	exists, err := repo.TemplateNameExists(ctx, "Refund", 0)
+	require.NoError(t, err)
+	assert.True(t, exists)
+	exists, err = repo.TemplateNameExists(ctx, "Refund", billing.ID)
+	require.NoError(t, err)
+	assert.False(t, exists)
+
+	billing.Active = false
+	billing.GroupIDs = nil
+	billing.Tags = []string{"money"}
+	require.NoError(t, repo.UpdateTemplate(ctx, billing))
+	got, err = repo.GetTemplateByID(ctx, billing.ID)
+	require.NoError(t, err)
+	assert.False(t, got.Active)
+	assert.Empty(t, got.GroupIDs)
+	assert.Equal(t, []string{"money"}, got.Tags)
+
+	active, err := repo.GetActiveTemplates(ctx)
+	require.NoError(t, err)
+	assert.Len(t, active, 2)
+	all, err := repo.GetAllTemplates(ctx)
+	require.NoError(t, err)
+	assert.Len(t, all, 3)
+
+	byCategory, err := repo.GetTemplatesByCategory(ctx, "Billing")
+	require.NoError(t, err)
+	require.Len(t, byCategory, 1)
+	assert.Equal(t, billing.ID, byCategory[0].ID)
+
+	found, err := repo.SearchTemplates(ctx, "LOGIN")
+	require.NoError(t, err)
+	require.Len(t, found, 1)
+	assert.Equal(t, public.ID, found[0].ID)
+
+	categories, err := repo.GetCategories(ctx)
+	require.NoError(t, err)
+	require.Len(t, categories, 2)
+	assert.Equal(t, "Accounts", categories[0].Name)
+	assert.Equal(t, "Hardware", categories[1].Name)
+
+	require.NoError(t, repo.IncrementUsageCount(ctx, public.ID))
+	require.NoError(t, repo.IncrementUsageCount(ctx, public.ID))
+	got, err = repo.GetTemplateByID(ctx, public.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 2, got.UsageCount)
+	assert.ErrorIs(t, repo.IncrementUsageCount(ctx, 999), ErrTicketTemplateNotFound)
+
+	require.NoError(t, repo.DeleteTemplate(ctx, hardware.ID))
+	_, err = repo.GetTemplateByID(ctx, hardware.ID)
+	assert.ErrorIs(t, err, ErrTicketTemplateNotFound)
+	assert.ErrorIs(t, repo.DeleteTemplate(ctx, hardware.ID), ErrTicketTemplateNotFound)
+	assert.ErrorIs(t, repo.UpdateTemplate(ctx, hardware), ErrTicketTemplateNotFound)
+}
@@ -0,0 +1,290 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/models"
+)
+
+const ticketTypeSelect = `
+	SELECT tt.id, tt.name, tt.valid_id, tt.create_time, tt.create_by, tt.change_time, tt.change_by,
+	       (SELECT COUNT(*) FROM ticket t WHERE t.type_id = tt.id)
+	FROM ticket_type tt`
+
+// TicketTypeRepository handles database operations for ticket types and
+// their workflows.
+type TicketTypeRepository struct {
+	db *sql.DB
+}
+
+// NewTicketTypeRepository creates a new ticket type repository.
+func NewTicketTypeRepository(db *sql.DB) *TicketTypeRepository {
+	return &TicketTypeRepository{db: db}
+}
+
+// List returns ticket types ordered by name, including invalid ones when
+// asked to.
+func (r *TicketTypeRepository) List(ctx context.Context, includeInvalid bool) ([]models.TicketType, error) {
+	query := ticketTypeSelect
+	if !includeInvalid {
+		query += " WHERE tt.valid_id = 1"
+	}
+	rows, err := r.db.QueryContext(ctx, database.ConvertPlaceholders(query+" ORDER BY tt.name"))
+	if err != nil {
+		return nil, fmt.Errorf("query ticket types: %w", err)
+	}
+	defer rows.Close()
+
+	types := make([]models.TicketType, 0)
+	for rows.Next() {
+		tt, err := scanTicketType(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan ticket type: %w", err)
+		}
+		types = append(types, *tt)
+	}
+	return types, rows.Err()
+}
+
+// Get returns a ticket type by ID, or nil if it does not exist.
+func (r *TicketTypeRepository) Get(ctx context.Context, id int) (*models.TicketType, error) {
+	row := r.db.QueryRowContext(ctx, database.ConvertPlaceholders(ticketTypeSelect+" WHERE tt.id = ?"), id)
+	tt, err := scanTicketType(row)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get ticket type: %w", err)
+	}
+	return tt, nil
+}
+
+// NameExists reports whether another ticket type already uses the name.
+// Names are unique across valid and invalid types.
+func (r *TicketTypeRepository) NameExists(ctx context.Context, name string, excludeID int) (bool, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx, database.ConvertPlaceholders(
+		"SELECT COUNT(*) FROM ticket_type WHERE LOWER(name) = LOWER(?) AND id <> ?",
+	), name, excludeID).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("check ticket type name: %w", err)
+	}
+	return count > 0, nil
+}
+
+// Create inserts a ticket type and returns its ID.
+func (r *TicketTypeRepository) Create(ctx context.Context, tt *models.TicketType) (int, error) {
+	now := time.Now()
+	query := database.ConvertPlaceholders(`
+		INSERT INTO ticket_type (name, valid_id, create_time, create_by, change_time, change_by)
+		VALUES (?, ?, ?, ?, ?, ?)
+		RETURNING id`)
+	id, err := database.GetAdapter().InsertWithReturning(r.db, query,
+		tt.Name, tt.ValidID, now, tt.CreateBy, now, tt.CreateBy)
+	if err != nil {
+		return 0, fmt.Errorf("insert ticket type: %w", err)
+	}
+	return int(id), nil
+}
+
+// Update stores the name and validity of a ticket type.
+func (r *TicketTypeRepository) Update(ctx context.Context, tt *models.TicketType, userID int) error {
+	result, err := r.db.ExecContext(ctx, database.ConvertPlaceholders(`
+		UPDATE ticket_type SET name = ?, valid_id = ?, change_time = ?, change_by = ?
+		WHERE id = ?
+	`), tt.Name, tt.ValidID, time.Now(), userID, tt.ID)
+	if err != nil {
+		return fmt.Errorf("update ticket type: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// Invalidate soft-deletes a ticket type by marking it invalid.
+func (r *TicketTypeRepository) Invalidate(ctx context.Context, id int, userID int) error {
+	result, err := r.db.ExecContext(ctx, database.ConvertPlaceholders(`
+		UPDATE ticket_type SET valid_id = 2, change_time = ?, change_by = ?
+		WHERE id = ?
+	`), time.Now(), userID, id)
+	if err != nil {
+		return fmt.Errorf("invalidate ticket type: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// GetWorkflow returns the workflow of a ticket type. A type without a stored
+// workflow gets an empty one: no defaults, every field and every transition.
+func (r *TicketTypeRepository) GetWorkflow(ctx context.Context, typeID int) (*models.TicketTypeWorkflow, error) {
+	wf := &models.TicketTypeWorkflow{
+		TypeID:        typeID,
+		DynamicFields: make([]models.TicketTypeField, 0),
+		Transitions:   make([]models.TicketTypeTransition, 0),
+	}
+
+	var queueID, priorityID, slaID sql.NullInt64
+	var changeTime time.Time
+	err := r.db.QueryRowContext(ctx, database.ConvertPlaceholders(`
+		SELECT default_queue_id, default_priority_id, default_sla_id, change_time, change_by
+		FROM ticket_type_workflow WHERE type_id = ?`), typeID).
+		Scan(&queueID, &priorityID, &slaID, &changeTime, &wf.ChangeBy)
+	switch {
+	case err == sql.ErrNoRows:
+	case err != nil:
+		return nil, fmt.Errorf("get ticket type workflow: %w", err)
+	default:
+		wf.DefaultQueueID = nullIntRef(queueID)
+		wf.DefaultPriorityID = nullIntRef(priorityID)
+		wf.DefaultSLAID = nullIntRef(slaID)
+		wf.ChangeTime = &changeTime
+	}
+
+	rows, err := r.db.QueryContext(ctx, database.ConvertPlaceholders(`
+		SELECT field_id, required FROM ticket_type_dynamic_field
+		WHERE type_id = ? ORDER BY field_id`), typeID)
+	if err != nil {
+		return nil, fmt.Errorf("query ticket type fields: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var f models.TicketTypeField
+		var required int
+		if err := rows.Scan(&f.FieldID, &required); err != nil {
+			return nil, fmt.Errorf("scan ticket type field: %w", err)
+		}
+		f.Required = required == 1
+		wf.DynamicFields = append(wf.DynamicFields, f)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	trows, err := r.db.QueryContext(ctx, database.ConvertPlaceholders(`
+		SELECT from_state_id, to_state_id FROM ticket_type_transition
+		WHERE type_id = ? ORDER BY from_state_id, to_state_id`), typeID)
+	if err != nil {
+		return nil, fmt.Errorf("query ticket type transitions: %w", err)
+	}
+	defer trows.Close()
+	for trows.Next() {
+		var t models.TicketTypeTransition
+		if err := trows.Scan(&t.FromStateID, &t.ToStateID); err != nil {
+			return nil, fmt.Errorf("scan ticket type transition: %w", err)
+		}
+		wf.Transitions = append(wf.Transitions, t)
+	}
+	return wf, trows.Err()
+}
+
+// SaveWorkflow replaces the workflow of a ticket type in one transaction.
+func (r *TicketTypeRepository) SaveWorkflow(ctx context.Context, wf *models.TicketTypeWorkflow, userID int) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin ticket type workflow: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	for _, table := range []string{"ticket_type_workflow", "ticket_type_dynamic_field", "ticket_type_transition"} {
+		if _, err := tx.ExecContext(ctx, database.ConvertPlaceholders(
+			"DELETE FROM "+table+" WHERE type_id = ?"), wf.TypeID); err != nil {
+			return fmt.Errorf("clear %s: %w", table, err)
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, database.ConvertPlaceholders(`
+		INSERT INTO ticket_type_workflow (type_id, default_queue_id, default_priority_id, default_sla_id,
+			change_time, change_by)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`), wf.TypeID, wf.DefaultQueueID, wf.DefaultPriorityID, wf.DefaultSLAID, time.Now(), userID)
+	if err != nil {
+		return fmt.Errorf("insert ticket type workflow: %w", err)
+	}
+
+	for _, f := range wf.DynamicFields {
+		required := 0
+		if f.Required {
+			required = 1
+		}
+		if _, err := tx.ExecContext(ctx, database.ConvertPlaceholders(`
+			INSERT INTO ticket_type_dynamic_field (type_id, field_id, required) VALUES (?, ?, ?)
+		`), wf.TypeID, f.FieldID, required); err != nil {
+			return fmt.Errorf("insert ticket type field %d: %w", f.FieldID, err)
+		}
+	}
+
+	for _, t := range wf.Transitions {
+		if _, err := tx.ExecContext(ctx, database.ConvertPlaceholders(`
+			INSERT INTO ticket_type_transition (type_id, from_state_id, to_state_id) VALUES (?, ?, ?)
+		`), wf.TypeID, t.FromStateID, t.ToStateID); err != nil {
+			return fmt.Errorf("insert ticket type transition %d->%d: %w", t.FromStateID, t.ToStateID, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// ValidRowExists reports whether table holds a valid row with the ID. The
+// table name must be a constant.
+func (r *TicketTypeRepository) ValidRowExists(ctx context.Context, table string, id int) (bool, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx, database.ConvertPlaceholders(
+		"SELECT COUNT(*) FROM "+table+" WHERE id = ? AND valid_id = 1",
+	), id).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("check %s %d: %w", table, id, err)
+	}
+	return count > 0, nil
+}
+
+// TicketDynamicFieldExists reports whether a valid ticket dynamic field with
+// the ID exists.
+func (r *TicketTypeRepository) TicketDynamicFieldExists(ctx context.Context, fieldID int) (bool, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx, database.ConvertPlaceholders(
+		"SELECT COUNT(*) FROM dynamic_field WHERE id = ? AND object_type = 'Ticket' AND valid_id = 1",
+	), fieldID).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("check dynamic field %d: %w", fieldID, err)
+	}
+	return count > 0, nil
+}
+
+// TicketTypeAndState returns the ticket's type and state; ok is false when
+// the ticket does not exist. typeID is 0 for tickets without a type.
+func (r *TicketTypeRepository) TicketTypeAndState(ctx context.Context, ticketID int) (typeID, stateID int, ok bool, err error) {
+	var tid sql.NullInt64
+	err = r.db.QueryRowContext(ctx, database.ConvertPlaceholders(
+		"SELECT type_id, ticket_state_id FROM ticket WHERE id = ?",
+	), ticketID).Scan(&tid, &stateID)
+	if err == sql.ErrNoRows {
+		return 0, 0, false, nil
+	}
+	if err != nil {
+		return 0, 0, false, fmt.Errorf("load ticket %d: %w", ticketID, err)
+	}
+	return int(tid.Int64), stateID, true, nil
+}
+
+func scanTicketType(row kbRowScanner) (*models.TicketType, error) {
+	var tt models.TicketType
+	if err := row.Scan(&tt.ID, &tt.Name, &tt.ValidID, &tt.CreateTime, &tt.CreateBy,
+		&tt.ChangeTime, &tt.ChangeBy, &tt.TicketCount); err != nil {
+		return nil, err
+	}
+	return &tt, nil
+}
+
+func nullIntRef(v sql.NullInt64) *int {
+	if !v.Valid {
+		return nil
+	}
+	id := int(v.Int64)
+	return &id
+}
@@ -10,17 +10,29 @@ import (
 
 	"github.com/goatkit/goatflow/internal/database"
 	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/secrets"
 	"gopkg.in/yaml.v3"
 )
 
+// webserviceSecretKeys are the config keys holding credentials, which are
+// stored encrypted when a master key is configured.
+var webserviceSecretKeys = map[string]bool{
+	"BasicAuthPassword":      true,
+	"APIKey":                 true,
+	"OAuth2ClientSecret":     true,
+	"JWTAuthKeyFilePassword": true,
+	"ProxyPassword":          true,
+}
+
 // WebserviceRepository handles database operations for GenericInterface webservices.
 type WebserviceRepository struct {
-	db *sql.DB
+	db      *sql.DB
+	keyring func() (*secrets.Keyring, error)
 }
 
 // NewWebserviceRepository creates a new webservice repository.
 func NewWebserviceRepository(db *sql.DB) *WebserviceRepository {
-	return &WebserviceRepository{db: db}
+	return &WebserviceRepository{db: db, keyring: secrets.Default}
 }
 
 // GetByID retrieves a webservice configuration by ID.
@@ -145,10 +157,9 @@ func (r *WebserviceRepository) ListValid(ctx context.Context) ([]*models.Webserv
 
 // Create creates a new webservice configuration.
 func (r *WebserviceRepository) Create(ctx context.Context, ws *models.WebserviceConfig, userID int) (int, error) {
-	// Serialize config to YAML
-	configYAML, err := yaml.Marshal(ws.Config)
+	configYAML, err := r.marshalConfig(ws.Config)
 	if err != nil {
-		return 0, fmt.Errorf("failed to serialize config: %w", err)
+		return 0, err
 	}
 
 	now := time.Now()
@@ -181,10 +192,9 @@ func (r *WebserviceRepository) Create(ctx context.Context, ws *models.Webservice
 
 // Update updates an existing webservice configuration.
 func (r *WebserviceRepository) Update(ctx context.Context, ws *models.WebserviceConfig, userID int) error {
-	// Serialize config to YAML
-	configYAML, err := yaml.Marshal(ws.Config)
+	configYAML, err := r.marshalConfig(ws.Config)
 	if err != nil {
-		return fmt.Errorf("failed to serialize config: %w", err)
+		return err
 	}
 
 	now := time.Now()
@@ -357,21 +367,166 @@ func (r *WebserviceRepository) RestoreFromHistory(ctx context.Context, historyID
 	return nil
 }
 
-// parseConfig parses the raw YAML config into structured data.
+// parseConfig parses the raw YAML config into structured data, decrypting
+// its credentials. ConfigRaw keeps them encrypted.
 func (r *WebserviceRepository) parseConfig(ws *models.WebserviceConfig) error {
 	if len(ws.ConfigRaw) == 0 {
 		ws.Config = &models.WebserviceConfigData{}
 		return nil
 	}
 
-	config := &models.WebserviceConfigData{}
-	if err := yaml.Unmarshal(ws.ConfigRaw, config); err != nil {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(ws.ConfigRaw, &doc); err != nil {
+		return err
+	}
+	keys, err := r.keyring()
+	if err != nil {
+		return err
+	}
+	if _, err := transformWebserviceSecrets(&doc, func(v string) (string, error) { return keys.Decrypt(v) }); err != nil {
 		return err
 	}
+	config := &models.WebserviceConfigData{}
+	if len(doc.Content) > 0 {
+		if err := doc.Decode(config); err != nil {
+			return err
+		}
+	}
 	ws.Config = config
 	return nil
 }
 
+// marshalConfig serializes a config to YAML with its credentials encrypted.
+func (r *WebserviceRepository) marshalConfig(config *models.WebserviceConfigData) ([]byte, error) {
+	var doc yaml.Node
+	if err := doc.Encode(config); err != nil {
+		return nil, fmt.Errorf("failed to serialize config: %w", err)
+	}
+	keys, err := r.keyring()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := transformWebserviceSecrets(&doc, keys.Encrypt); err != nil {
+		return nil, fmt.Errorf("failed to encrypt config credentials: %w", err)
+	}
+	configYAML, err := yaml.Marshal(&doc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize config: %w", err)
+	}
+	return configYAML, nil
+}
+
+// transformWebserviceSecrets replaces the credential values anywhere in a
+// config document with fn's result, reporting whether any changed.
+func transformWebserviceSecrets(node *yaml.Node, fn func(string) (string, error)) (bool, error) {
+	changed := false
+	if node.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if webserviceSecretKeys[key.Value] && value.Kind == yaml.ScalarNode {
+				v, err := fn(value.Value)
+				if err != nil {
+					return changed, fmt.Errorf("%s: %w", key.Value, err)
+				}
+				if v != value.Value {
+					value.Value, value.Tag, value.Style = v, "!!str", 0
+					changed = true
+				}
+				continue
+			}
+			c, err := transformWebserviceSecrets(value, fn)
+			changed = changed || c
+			if err != nil {
+				return changed, err
+			}
+		}
+		return changed, nil
+	}
+	for _, child := range node.Content {
+		c, err := transformWebserviceSecrets(child, fn)
+		changed = changed || c
+		if err != nil {
+			return changed, err
+		}
+	}
+	return changed, nil
+}
+
+// RotateSecrets re-encrypts the credentials of all webservice configs and
+// their history under the current master key, encrypting any still stored
+// in plaintext. It returns how many rows changed.
+func (r *WebserviceRepository) RotateSecrets(ctx context.Context) (int, error) {
+	keys, err := r.keyring()
+	if err != nil {
+		return 0, err
+	}
+	if keys == nil {
+		return 0, secrets.ErrNoKey
+	}
+	rotate := func(v string) (string, error) {
+		s, _, err := keys.Rotate(v)
+		return s, err
+	}
+
+	type row struct {
+		id     int64
+		config []byte
+	}
+	load := func(query string) ([]row, error) {
+		rows, err := r.db.QueryContext(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+		var list []row
+		for rows.Next() {
+			var rw row
+			if err := rows.Scan(&rw.id, &rw.config); err != nil {
+				return nil, err
+			}
+			list = append(list, rw)
+		}
+		return list, rows.Err()
+	}
+
+	changed := 0
+	for _, table := range []string{"gi_webservice_config", "gi_webservice_config_history"} {
+		list, err := load("SELECT id, config FROM " + table + " ORDER BY id")
+		if err != nil {
+			return changed, fmt.Errorf("failed to read %s: %w", table, err)
+		}
+		for _, rw := range list {
+			var doc yaml.Node
+			if len(rw.config) == 0 || yaml.Unmarshal(rw.config, &doc) != nil {
+				continue
+			}
+			c, err := transformWebserviceSecrets(&doc, rotate)
+			if err != nil {
+				return changed, fmt.Errorf("%s %d: %w", table, rw.id, err)
+			}
+			if !c {
+				continue
+			}
+			configYAML, err := yaml.Marshal(&doc)
+			if err != nil {
+				return changed, fmt.Errorf("%s %d: %w", table, rw.id, err)
+			}
+			query := "UPDATE gi_webservice_config SET config = ? WHERE id = ?"
+			args := []interface{}{configYAML, rw.id}
+			if table == "gi_webservice_config_history" {
+				hash := md5.Sum(configYAML)
+				query = "UPDATE gi_webservice_config_history SET config = ?, config_md5 = ? WHERE id = ?"
+				args = []interface{}{configYAML, hex.EncodeToString(hash[:]), rw.id}
+			}
+			if _, err := r.db.ExecContext(ctx, database.ConvertPlaceholders(query), args...); err != nil {
+				return changed, fmt.Errorf("failed to update %s %d: %w", table, rw.id, err)
+			}
+			changed++
+		}
+	}
+	return changed, nil
+}
+
 // createHistoryEntry creates a history entry for config changes.
 func (r *WebserviceRepository) createHistoryEntry(ctx context.Context, configID int, configYAML []byte, userID int) error {
 	// Calculate MD5 hash
@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/secrets"
+	"github.com/goatkit/goatflow/internal/testutil"
+)
+
+func TestWebserviceRepository_EncryptsCredentials(t *testing.T) {
+	db := testutil.UseMigratedDB(t)
+	ctx := context.Background()
+	oldKey, newKey := bytes.Repeat([]byte{1}, 32), bytes.Repeat([]byte{2}, 32)
+	keys, err := secrets.NewKeyring(oldKey)
+	require.NoError(t, err)
+	repo := NewWebserviceRepository(db)
+	repo.keyring = func() (*secrets.Keyring, error) { return keys, nil }
+
+	// A config written before a master key was configured
+	_, err = db.Exec(`INSERT INTO gi_webservice_config (name, config, valid_id, create_time, create_by, change_time, change_by)
+		VALUES ('Legacy', 'Requester:
+    Transport:
+        Config:
+            Authentication:
+                AuthType: BasicAuth
+                BasicAuthPassword: plain-pw
+', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`)
+	require.NoError(t, err)
+
+	ws := &models.WebserviceConfig{Name: "CRM", ValidID: 1, Config: &models.WebserviceConfigData{
+		Requester: models.RequesterConfig{Transport: models.TransportConfig{Type: "HTTP::REST", Config: models.TransportHTTPConfig{
+			Host:           "https://crm.example.com",
+			Authentication: models.AuthConfig{AuthType: "BasicAuth", BasicAuthUser: "sync", BasicAuthPassword: "hunter2"},
+			Proxy:          models.ProxyConfig{UseProxy: "1", ProxyPassword: "proxy-pw"},
+		}}},
+	}}
+	id, err := repo.Create(ctx, ws, 1)
+	require.NoError(t, err)
+
+	var raw string
+	require.NoError(t, db.QueryRow("SELECT config FROM gi_webservice_config WHERE id = ?", id).Scan(&raw))
+	assert.NotContains(t, raw, "hunter2")
+	assert.NotContains(t, raw, "proxy-pw")
+	assert.Contains(t, raw, "BasicAuthUser: sync", "only credentials are encrypted")
+	require.NoError(t, db.QueryRow("SELECT config FROM gi_webservice_config_history WHERE config_id = ?", id).Scan(&raw))
+	assert.NotContains(t, raw, "hunter2")
+
+	got, err := repo.GetByID(ctx, id)
+	require.NoError(t, err)
+	auth := got.Config.Requester.Transport.Config.Authentication
+	assert.Equal(t, "hunter2", auth.BasicAuthPassword)
+	assert.Equal(t, "proxy-pw", got.Config.Requester.Transport.Config.Proxy.ProxyPassword)
+
+	legacy, err := repo.GetByName(ctx, "Legacy")
+	require.NoError(t, err)
+	assert.Equal(t, "plain-pw", legacy.Config.Requester.Transport.Config.Authentication.BasicAuthPassword)
+
+	// Without the key the credentials cannot be read
+	repo.keyring = func() (*secrets.Keyring, error) { return nil, nil }
+	_, err = repo.GetByID(ctx, id)
+	assert.ErrorIs(t, err, secrets.ErrNoKey)
+
+	// Rotating moves everything to the new key, plaintext included
+	rotating, err := secrets.NewKeyring(newKey, oldKey)
+	require.NoError(t, err)
+	repo.keyring = func() (*secrets.Keyring, error) { return rotating, nil }
+	changed, err := repo.RotateSecrets(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 3, changed, "two configs and one history entry")
+	changed, err = repo.RotateSecrets(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, changed)
+
+	current, err := secrets.NewKeyring(newKey)
+	require.NoError(t, err)
+	repo.keyring = func() (*secrets.Keyring, error) { return current, nil }
+	got, err = repo.GetByID(ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, "hunter2", got.Config.Requester.Transport.Config.Authentication.BasicAuthPassword)
+	require.NoError(t, db.QueryRow("SELECT config FROM gi_webservice_config WHERE name = 'Legacy'").Scan(&raw))
+	assert.NotContains(t, raw, "plain-pw")
+}
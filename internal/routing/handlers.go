@@ -63,8 +63,9 @@ func RegisterExistingHandlers(registry *HandlerRegistry) {
 			path := c.Request.URL.Path
 			if path == "/login" || path == "/login/2fa" || path == "/api/auth/login" || path == "/api/auth/2fa/verify" ||
 				path == "/customer/login" || path == "/customer/login/2fa" || path == "/api/auth/customer/login" || path == "/api/auth/customer/2fa/verify" ||
+				strings.HasPrefix(path, "/api/auth/2fa/webauthn/") || strings.HasPrefix(path, "/api/auth/customer/2fa/webauthn/") ||
 				path == "/health" || path == "/metrics" || path == "/favicon.ico" || strings.HasPrefix(path, "/static/") ||
-				path == "/auth/customer" || path == "/api/languages" || path == "/api/themes" || strings.HasPrefix(path, "/swagger/") {
+				path == "/auth/customer" || strings.HasPrefix(path, "/auth/sso/") || path == "/api/languages" || path == "/api/themes" || strings.HasPrefix(path, "/swagger/") {
 				c.Next()
 				return
 			}
@@ -258,6 +259,11 @@ func RegisterExistingHandlers(registry *HandlerRegistry) {
 			} else {
 				c.Set("is_customer", false)
 			}
+			c.Set("claims", claims)
+
+			if !middleware.EnforceTwoFactor(c, claims) || !middleware.EnforcePasswordChange(c, claims) {
+				return
+			}
 
 			c.Next()
 		},
@@ -273,6 +279,9 @@ func RegisterExistingHandlers(registry *HandlerRegistry) {
 				c.Abort()
 				return
 			}
+			if !middleware.RequireAdminTwoFactor(c) {
+				return
+			}
 			c.Next()
 		},
 
@@ -324,19 +333,29 @@ func RegisterExistingHandlers(registry *HandlerRegistry) {
 		"ticket_access_priority":  middleware.RequireQueueAccessFromTicket("priority"),
 		"ticket_access_move_into": middleware.RequireQueueAccessFromTicket("move_into"),
 
+		// Ticket lock middleware - rejects replies while another agent holds the lock
+		"ticket_unlocked": middleware.RequireTicketUnlockedForUser(),
+
+		// Idempotency-Key support - replays the stored response on retries
+		"idempotent": middleware.RequireIdempotency(),
+
+		// Request capture - records API requests for later replay
+		"request_capture": middleware.CaptureRequests(),
+
 		// API token authentication
 		"api_token":    middleware.APITokenAuthMiddleware(),
 		"unified_auth": middleware.UnifiedAuthMiddleware(shared.GetJWTManager()),
 
-		// API token scope middleware - restricts API token access
-		"scope_tickets_read":   middleware.RequireScope("tickets:read"),
-		"scope_tickets_write":  middleware.RequireScope("tickets:write"),
-		"scope_tickets_delete": middleware.RequireScope("tickets:delete"),
-		"scope_articles_read":  middleware.RequireScope("articles:read"),
-		"scope_articles_write": middleware.RequireScope("articles:write"),
-		"scope_users_read":     middleware.RequireScope("users:read"),
-		"scope_queues_read":    middleware.RequireScope("queues:read"),
-		"scope_admin":          middleware.RequireScope("admin:*"),
+		// Lets CalDAV clients prompt for an API token
+		"caldav_challenge": middleware.BasicAuthChallenge("GoatFlow CalDAV"),
+	}
+
+	// API token scope middleware - one per registered scope, named by
+	// ScopeMiddlewareName (scope_tickets_read, scope_admin, ...)
+	for _, def := range models.GetAllScopes() {
+		if def.Scope != "*" {
+			middlewares[ScopeMiddlewareName(def.Scope)] = middleware.RequireScope(def.Scope)
+		}
 	}
 
 	// Register all middleware
@@ -10,6 +10,7 @@ import (
 
 	"github.com/goatkit/goatflow/internal/database"
 	"github.com/goatkit/goatflow/internal/middleware"
+	"github.com/goatkit/goatflow/internal/models"
 	"github.com/goatkit/goatflow/internal/shared"
 )
 
@@ -354,20 +355,17 @@ func registerExistingMiddleware(registry *HandlerRegistry, jwtManager interface{
 
 		// Note: api_token and unified_auth are registered in handlers.go
 
-		// API token scope middleware - restricts API token access
-		"scope_tickets_read":   middleware.RequireScope("tickets:read"),
-		"scope_tickets_write":  middleware.RequireScope("tickets:write"),
-		"scope_tickets_delete": middleware.RequireScope("tickets:delete"),
-		"scope_articles_read":  middleware.RequireScope("articles:read"),
-		"scope_articles_write": middleware.RequireScope("articles:write"),
-		"scope_users_read":     middleware.RequireScope("users:read"),
-		"scope_queues_read":    middleware.RequireScope("queues:read"),
-		"scope_admin":          middleware.RequireScope("admin:*"),
-
 		// Demo mode guard - blocks password/MFA changes for non-admin users
 		"demo-guard": middleware.DemoGuard(),
 	}
 
+	// API token scope middleware - restricts API token access
+	for _, def := range models.GetAllScopes() {
+		if def.Scope != "*" {
+			middlewares[ScopeMiddlewareName(def.Scope)] = middleware.RequireScope(def.Scope)
+		}
+	}
+
 	return registry.RegisterMiddlewareBatch(middlewares)
 }
 
@@ -13,6 +13,8 @@ import (
 	"github.com/fsnotify/fsnotify"
 	"github.com/gin-gonic/gin"
 	"gopkg.in/yaml.v3"
+
+	"github.com/goatkit/goatflow/internal/middleware"
 )
 
 // RouteLoader manages loading and registering routes from YAML files.
@@ -265,7 +267,7 @@ func (l *RouteLoader) registerRouteConfig(config *RouteConfig) error {
 
 	// Apply group middleware
 	for _, middlewareName := range config.Spec.Middleware {
-		middleware, err := l.registry.GetMiddleware(middlewareName)
+		mw, err := l.registry.GetMiddleware(middlewareName)
 		if err != nil {
 			if l.strictMode {
 				return fmt.Errorf("middleware '%s' not found", middlewareName)
@@ -273,7 +275,7 @@ func (l *RouteLoader) registerRouteConfig(config *RouteConfig) error {
 			log.Printf("Warning: Middleware '%s' not found, skipping", middlewareName)
 			continue
 		}
-		group.Use(middleware)
+		group.Use(middleware.TraceHandler("middleware "+middlewareName, mw))
 	}
 
 	// Register individual routes
@@ -306,7 +308,7 @@ func (l *RouteLoader) registerRoute(group *gin.RouterGroup, route *RouteDefiniti
 	// Build middleware chain for this route
 	middlewareChain := make([]gin.HandlerFunc, 0)
 	for _, middlewareName := range route.Middleware {
-		middleware, err := l.registry.GetMiddleware(middlewareName)
+		mw, err := l.registry.GetMiddleware(middlewareName)
 		if err != nil {
 			if l.strictMode {
 				return fmt.Errorf("middleware '%s' not found", middlewareName)
@@ -314,7 +316,7 @@ func (l *RouteLoader) registerRoute(group *gin.RouterGroup, route *RouteDefiniti
 			log.Printf("Warning: Middleware '%s' not found for route %s", middlewareName, route.Path)
 			continue
 		}
-		middlewareChain = append(middlewareChain, middleware)
+		middlewareChain = append(middlewareChain, middleware.TraceHandler("middleware "+middlewareName, mw))
 	}
 
 	// Register based on method(s)
@@ -328,11 +330,13 @@ func (l *RouteLoader) registerRoute(group *gin.RouterGroup, route *RouteDefiniti
 			log.Printf("Warning: Handler '%s' not found for route %s", route.Handler, route.Path)
 			return nil
 		}
+		handler = middleware.TraceHandler("handler "+route.Handler, handler)
 
 		// Determine methods
 		methods := l.parseMethods(route.Method)
 		for _, method := range methods {
 			l.registerMethodRoute(group, method, route.Path, append(middlewareChain, handler)...)
+			l.recordRoute(group, method, route.Handler, route, config)
 		}
 	} else if len(route.Handlers) > 0 {
 		// Different handlers for different methods
@@ -345,14 +349,31 @@ func (l *RouteLoader) registerRoute(group *gin.RouterGroup, route *RouteDefiniti
 				log.Printf("Warning: Handler '%s' not found for %s %s", handlerName, method, route.Path)
 				continue
 			}
+			handler = middleware.TraceHandler("handler "+handlerName, handler)
 
 			l.registerMethodRoute(group, method, route.Path, append(middlewareChain, handler)...)
+			l.recordRoute(group, method, handlerName, route, config)
 		}
 	}
 
 	return nil
 }
 
+// recordRoute adds a registered route to the sitemap.
+func (l *RouteLoader) recordRoute(group *gin.RouterGroup, method, handlerName string, route *RouteDefinition, config *RouteConfig) {
+	e := RouteEntry{
+		Method:      strings.ToUpper(method),
+		Path:        joinRoutePath(group.BasePath(), normalizeRoutePath(group.BasePath(), route.Path)),
+		Source:      RouteSourceYAML,
+		Module:      config.Metadata.Name,
+		Handler:     handlerName,
+		Description: route.Description,
+		Middleware:  append(append([]string{}, config.Spec.Middleware...), route.Middleware...),
+	}
+	DescribeMiddleware(&e, e.Middleware, l.registry.MiddlewareExists)
+	RecordRoute(e)
+}
+
 // registerMethodRoute registers a route for a specific HTTP method.
 func (l *RouteLoader) registerMethodRoute(group *gin.RouterGroup, method, path string, handlers ...gin.HandlerFunc) {
 	resolved := normalizeRoutePath(group.BasePath(), path)
@@ -374,6 +395,9 @@ func (l *RouteLoader) registerMethodRoute(group *gin.RouterGroup, method, path s
 		group.OPTIONS(resolved, handlers...)
 	case "ANY":
 		group.Any(resolved, handlers...)
+	case "PROPFIND", "REPORT":
+		// WebDAV methods, used by the read-only CalDAV endpoints
+		group.Handle(strings.ToUpper(method), resolved, handlers...)
 	default:
 		log.Printf("Warning: Unknown HTTP method '%s' for route %s", method, path)
 	}
@@ -563,6 +587,7 @@ func LoadYAMLRoutesFromGlobalMap(router *gin.Engine, routesPath string) error {
 		return fmt.Errorf("failed to create route loader: %w", err)
 	}
 
+	setSitemapEngine(router)
 	return loader.LoadRoutes()
 }
 
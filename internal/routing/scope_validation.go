@@ -0,0 +1,74 @@
+package routing
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/goatkit/goatflow/internal/models"
+)
+
+// APIScopePrefix is the path prefix of routes that must declare a scope.
+const APIScopePrefix = "/api/v1"
+
+// ScopeMiddlewareName returns the route middleware name that enforces a
+// scope: "tickets:read" is scope_tickets_read and "admin:*" is scope_admin.
+func ScopeMiddlewareName(scope string) string {
+	return "scope_" + strings.ReplaceAll(strings.TrimSuffix(scope, ":*"), ":", "_")
+}
+
+// scopeForMiddleware returns the registered scope a scope_ middleware name
+// enforces.
+func scopeForMiddleware(name string) (string, bool) {
+	for _, def := range models.GetAllScopes() {
+		if def.Scope != "*" && ScopeMiddlewareName(def.Scope) == name {
+			return def.Scope, true
+		}
+	}
+	return "", false
+}
+
+// ScopeIssue is an API route that API tokens can reach without a registered
+// scope.
+type ScopeIssue struct {
+	Method  string
+	Path    string
+	Module  string
+	Problem string
+}
+
+func (i ScopeIssue) String() string {
+	return fmt.Sprintf("%s %s (%s): %s", i.Method, i.Path, i.Module, i.Problem)
+}
+
+// ValidateRouteScopes checks that every route under APIScopePrefix that
+// accepts API tokens declares at least one registered scope. Session-only and
+// public routes are skipped because scopes only apply to tokens; routes added
+// in code are reported because their middleware cannot be inspected.
+func ValidateRouteScopes(entries []RouteEntry) []ScopeIssue {
+	var issues []ScopeIssue
+	for _, e := range entries {
+		if !e.Mounted || (e.Path != APIScopePrefix && !strings.HasPrefix(e.Path, APIScopePrefix+"/")) {
+			continue
+		}
+		issue := ScopeIssue{Method: e.Method, Path: e.Path, Module: e.Module}
+		for _, name := range e.UnresolvedMiddleware {
+			if strings.HasPrefix(name, "scope_") {
+				issue.Problem = "unknown scope middleware " + name
+				issues = append(issues, issue)
+			}
+		}
+		switch e.Auth {
+		case RouteAuthPublic, RouteAuthSession:
+			continue
+		case RouteAuthUnknown:
+			issue.Problem = "registered in code; scope cannot be verified"
+			issues = append(issues, issue)
+			continue
+		}
+		if len(e.Scopes) == 0 {
+			issue.Problem = "accepts API tokens but declares no scope"
+			issues = append(issues, issue)
+		}
+	}
+	return issues
+}
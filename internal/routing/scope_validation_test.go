@@ -0,0 +1,42 @@
+package routing
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScopeMiddlewareName(t *testing.T) {
+	assert.Equal(t, "scope_tickets_read", ScopeMiddlewareName("tickets:read"))
+	assert.Equal(t, "scope_admin", ScopeMiddlewareName("admin:*"))
+	assert.Equal(t, "scope_plugin_calendar_read", ScopeMiddlewareName("plugin:calendar:read"))
+
+	scope, ok := scopeForMiddleware("scope_kb_write")
+	assert.True(t, ok)
+	assert.Equal(t, "kb:write", scope)
+	_, ok = scopeForMiddleware("scope_bogus_read")
+	assert.False(t, ok)
+}
+
+func TestValidateRouteScopes(t *testing.T) {
+	entries := []RouteEntry{
+		{Method: "POST", Path: "/api/v1/auth/login", Auth: RouteAuthPublic, Mounted: true},
+		{Method: "GET", Path: "/api/v1/tickets", Auth: RouteAuthSessionOrToken, Scopes: []string{"tickets:read"}, Mounted: true},
+		{Method: "GET", Path: "/api/v1/groups", Module: "api-v1", Auth: RouteAuthSessionOrToken, Mounted: true},
+		{Method: "GET", Path: "/api/v1/ldap/config", Module: "api", Auth: RouteAuthUnknown, Mounted: true},
+		{Method: "GET", Path: "/api/v1/kb", Auth: RouteAuthAPIToken, Scopes: []string{"kb:read"},
+			UnresolvedMiddleware: []string{"scope_kb_list"}, Mounted: true},
+		{Method: "GET", Path: "/api/v1/settings", Auth: RouteAuthSession, Mounted: true},
+		{Method: "GET", Path: "/api/v1x/other", Auth: RouteAuthSessionOrToken, Mounted: true},
+		{Method: "GET", Path: "/admin/users", Auth: RouteAuthSessionOrToken, Mounted: true},
+		{Method: "POST", Path: "/api/v1/ws/Ticket", Auth: RouteAuthUnknown},
+	}
+
+	issues := ValidateRouteScopes(entries)
+	require.Len(t, issues, 3)
+	assert.Equal(t, "GET /api/v1/groups (api-v1): accepts API tokens but declares no scope", issues[0].String())
+	assert.Equal(t, "/api/v1/ldap/config", issues[1].Path)
+	assert.Contains(t, issues[1].Problem, "registered in code")
+	assert.Equal(t, "unknown scope middleware scope_kb_list", issues[2].Problem)
+}
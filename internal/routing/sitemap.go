@@ -0,0 +1,208 @@
+package routing
+
+import (
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Route sources reported in the sitemap.
+const (
+	RouteSourceYAML             = "yaml"
+	RouteSourcePlugin           = "plugin"
+	RouteSourceCode             = "code"
+	RouteSourceGenericInterface = "genericinterface"
+)
+
+// Authentication modes reported in the sitemap.
+const (
+	RouteAuthPublic         = "public"
+	RouteAuthSession        = "session"
+	RouteAuthAPIToken       = "api_token"
+	RouteAuthSessionOrToken = "session_or_token"
+	RouteAuthUnknown        = "unknown"
+)
+
+// RouteEntry describes a route for the operator sitemap.
+type RouteEntry struct {
+	Method      string `json:"method"`
+	Path        string `json:"path"`
+	Source      string `json:"source"`
+	Module      string `json:"module"` // Route group, plugin name or Go package
+	Handler     string `json:"handler,omitempty"`
+	Description string `json:"description,omitempty"`
+	Auth        string `json:"auth"`
+	Role        string `json:"role,omitempty"` // admin, agent or customer
+	// Scopes an API token needs for this route.
+	Scopes []string `json:"scopes,omitempty"`
+	// Groups and Queues restrict the route to agents with Permission on one
+	// of them; "*" means the queue is taken from the request.
+	Groups     []string `json:"groups,omitempty"`
+	Queues     []string `json:"queues,omitempty"`
+	Permission string   `json:"permission,omitempty"`
+	Middleware []string `json:"middleware,omitempty"`
+	// UnresolvedMiddleware lists middleware named by the route that was not
+	// found at registration and is therefore not enforced.
+	UnresolvedMiddleware []string `json:"unresolved_middleware,omitempty"`
+	// Mounted is false for routes that are configured but not served.
+	Mounted bool `json:"mounted"`
+}
+
+var routeIndex = struct {
+	sync.RWMutex
+	entries map[string]RouteEntry
+	engine  *gin.Engine
+}{entries: map[string]RouteEntry{}}
+
+func routeKey(method, fullPath string) string {
+	return strings.ToUpper(method) + " " + fullPath
+}
+
+// RecordRoute adds route metadata for the sitemap. Routes registered without
+// a record are still listed, with what can be derived from their handler.
+func RecordRoute(e RouteEntry) {
+	e.Mounted = true
+	routeIndex.Lock()
+	defer routeIndex.Unlock()
+	routeIndex.entries[routeKey(e.Method, e.Path)] = e
+}
+
+// setSitemapEngine remembers the engine whose routes the sitemap lists.
+func setSitemapEngine(r *gin.Engine) {
+	routeIndex.Lock()
+	defer routeIndex.Unlock()
+	routeIndex.engine = r
+}
+
+// Sitemap lists every route registered on the main router, sorted by path.
+func Sitemap() []RouteEntry {
+	routeIndex.RLock()
+	defer routeIndex.RUnlock()
+	if routeIndex.engine == nil {
+		return nil
+	}
+	return buildSitemap(routeIndex.engine.Routes(), routeIndex.entries)
+}
+
+func buildSitemap(routes gin.RoutesInfo, records map[string]RouteEntry) []RouteEntry {
+	out := make([]RouteEntry, 0, len(routes))
+	for _, ri := range routes {
+		if e, ok := records[routeKey(ri.Method, ri.Path)]; ok {
+			out = append(out, e)
+			continue
+		}
+		if e, ok := records[routeKey("ANY", ri.Path)]; ok {
+			e.Method = ri.Method
+			out = append(out, e)
+			continue
+		}
+		module, handler := splitHandlerName(ri.Handler)
+		out = append(out, RouteEntry{
+			Method:  ri.Method,
+			Path:    ri.Path,
+			Source:  RouteSourceCode,
+			Module:  module,
+			Handler: handler,
+			Auth:    RouteAuthUnknown,
+			Mounted: true,
+		})
+	}
+	SortRouteEntries(out)
+	return out
+}
+
+// SortRouteEntries orders entries by path, then method.
+func SortRouteEntries(entries []RouteEntry) {
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Path != entries[j].Path {
+			return entries[i].Path < entries[j].Path
+		}
+		return entries[i].Method < entries[j].Method
+	})
+}
+
+// splitHandlerName turns a gin handler name such as
+// "github.com/goatkit/goatflow/internal/api/v1.(*APIRouter).handleListWebhooks-fm"
+// into its package ("api/v1") and function ("(*APIRouter).handleListWebhooks").
+func splitHandlerName(name string) (module, handler string) {
+	name = strings.TrimSuffix(name, "-fm")
+	slash := strings.LastIndex(name, "/")
+	dot := strings.Index(name[slash+1:], ".")
+	if dot < 0 {
+		return "", name
+	}
+	pkg := name[:slash+1+dot]
+	handler = name[slash+2+dot:]
+	for _, prefix := range []string{"github.com/goatkit/goatflow/internal/", "github.com/goatkit/goatflow/"} {
+		if strings.HasPrefix(pkg, prefix) {
+			return strings.TrimPrefix(pkg, prefix), handler
+		}
+	}
+	return pkg, handler
+}
+
+// DescribeMiddleware derives the access requirements of a route from its
+// middleware names. Names that exists reports as missing are left out of
+// the requirements and returned as unresolved.
+func DescribeMiddleware(e *RouteEntry, names []string, exists func(string) bool) {
+	session, token := false, false
+	for _, name := range names {
+		if exists != nil && !exists(name) {
+			e.UnresolvedMiddleware = append(e.UnresolvedMiddleware, name)
+			continue
+		}
+		switch {
+		case name == "auth":
+			session = true
+		case name == "api_token":
+			token = true
+		case name == "unified_auth":
+			session, token = true, true
+		case name == "admin" || name == "agent" || name == "customer":
+			e.Role = name
+		case name == "customer-portal":
+			if e.Role == "" {
+				e.Role = "customer"
+			}
+		case strings.HasPrefix(name, "scope_"):
+			if scope, ok := scopeForMiddleware(name); ok {
+				e.Scopes = append(e.Scopes, scope)
+			}
+		case strings.HasPrefix(name, "queue_access_"), strings.HasPrefix(name, "ticket_access_"):
+			e.Queues = []string{"*"}
+			e.Permission = name[strings.LastIndex(name, "access_")+len("access_"):]
+		case strings.HasPrefix(name, "queue_"):
+			e.Queues = []string{"*"}
+			e.Permission = strings.TrimPrefix(name, "queue_")
+		}
+	}
+	switch {
+	case session && token:
+		e.Auth = RouteAuthSessionOrToken
+	case token:
+		e.Auth = RouteAuthAPIToken
+	case session:
+		e.Auth = RouteAuthSession
+	default:
+		e.Auth = RouteAuthPublic
+	}
+	if e.Role == "admin" {
+		e.Groups = []string{"admin"}
+	}
+}
+
+// joinRoutePath joins a group base path and a relative route path the way
+// gin does.
+func joinRoutePath(base, rel string) string {
+	if rel == "" {
+		return base
+	}
+	joined := path.Join(base, rel)
+	if strings.HasSuffix(rel, "/") && !strings.HasSuffix(joined, "/") {
+		return joined + "/"
+	}
+	return joined
+}
@@ -0,0 +1,126 @@
+package routing
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDescribeMiddleware(t *testing.T) {
+	exists := func(name string) bool { return name != "customer_auth" }
+
+	tests := []struct {
+		name  string
+		mw    []string
+		check func(t *testing.T, e RouteEntry)
+	}{
+		{"public", nil, func(t *testing.T, e RouteEntry) {
+			assert.Equal(t, RouteAuthPublic, e.Auth)
+		}},
+		{"admin session", []string{"auth", "admin"}, func(t *testing.T, e RouteEntry) {
+			assert.Equal(t, RouteAuthSession, e.Auth)
+			assert.Equal(t, "admin", e.Role)
+			assert.Equal(t, []string{"admin"}, e.Groups)
+		}},
+		{"token scopes", []string{"unified_auth", "scope_tickets_write", "scope_admin"}, func(t *testing.T, e RouteEntry) {
+			assert.Equal(t, RouteAuthSessionOrToken, e.Auth)
+			assert.Equal(t, []string{"tickets:write", "admin:*"}, e.Scopes)
+		}},
+		{"queue permission", []string{"auth", "queue_access_move_into"}, func(t *testing.T, e RouteEntry) {
+			assert.Equal(t, []string{"*"}, e.Queues)
+			assert.Equal(t, "move_into", e.Permission)
+		}},
+		{"ticket permission", []string{"auth", "ticket_access_note"}, func(t *testing.T, e RouteEntry) {
+			assert.Equal(t, "note", e.Permission)
+		}},
+		{"unresolved middleware is not enforced", []string{"customer_auth"}, func(t *testing.T, e RouteEntry) {
+			assert.Equal(t, RouteAuthPublic, e.Auth)
+			assert.Equal(t, []string{"customer_auth"}, e.UnresolvedMiddleware)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var e RouteEntry
+			DescribeMiddleware(&e, tt.mw, exists)
+			tt.check(t, e)
+		})
+	}
+}
+
+func TestSplitHandlerName(t *testing.T) {
+	module, handler := splitHandlerName("github.com/goatkit/goatflow/internal/api/v1.(*APIRouter).handleListWebhooks-fm")
+	assert.Equal(t, "api/v1", module)
+	assert.Equal(t, "(*APIRouter).handleListWebhooks", handler)
+
+	module, handler = splitHandlerName("github.com/gin-gonic/gin.LoggerWithConfig.func1")
+	assert.Equal(t, "github.com/gin-gonic/gin", module)
+	assert.Equal(t, "LoggerWithConfig.func1", handler)
+}
+
+func TestSitemapListsYAMLAndCodeRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	dir := t.TempDir()
+	yaml := `apiVersion: v1
+kind: RouteGroup
+metadata:
+  name: sitemap-test
+  enabled: true
+spec:
+  prefix: /sitemap-test
+  middleware:
+    - unified_auth
+  routes:
+    - path: /
+      method: GET
+      handler: handleSitemapTest
+      description: "List things"
+    - path: /:id
+      method: [PUT, DELETE]
+      handler: handleSitemapTest
+      middleware:
+        - scope_tickets_write
+`
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "sitemap.yaml"), []byte(yaml), 0o644))
+
+	router := gin.New()
+	registry := NewHandlerRegistry()
+	ok := func(c *gin.Context) { c.Status(http.StatusNoContent) }
+	require.NoError(t, registry.RegisterMiddleware("unified_auth", ok))
+	require.NoError(t, registry.RegisterMiddleware("scope_tickets_write", ok))
+	require.NoError(t, registry.Register("handleSitemapTest", ok))
+	require.NoError(t, LoadYAMLRoutes(router, dir, registry))
+	router.GET("/sitemap-test-code", ok)
+
+	routeIndex.RLock()
+	entries := buildSitemap(router.Routes(), routeIndex.entries)
+	routeIndex.RUnlock()
+
+	byKey := map[string]RouteEntry{}
+	for _, e := range entries {
+		byKey[e.Method+" "+e.Path] = e
+	}
+	require.Len(t, byKey, 4)
+
+	list := byKey["GET /sitemap-test"]
+	assert.Equal(t, RouteSourceYAML, list.Source)
+	assert.Equal(t, "sitemap-test", list.Module)
+	assert.Equal(t, "handleSitemapTest", list.Handler)
+	assert.Equal(t, "List things", list.Description)
+	assert.Equal(t, RouteAuthSessionOrToken, list.Auth)
+	assert.True(t, list.Mounted)
+
+	del := byKey["DELETE /sitemap-test/:id"]
+	assert.Equal(t, []string{"tickets:write"}, del.Scopes)
+	assert.Equal(t, []string{"unified_auth", "scope_tickets_write"}, del.Middleware)
+
+	code := byKey["GET /sitemap-test-code"]
+	assert.Equal(t, RouteSourceCode, code.Source)
+	assert.Equal(t, RouteAuthUnknown, code.Auth)
+	assert.Equal(t, "routing", code.Module)
+}
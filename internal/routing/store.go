@@ -658,6 +658,8 @@ func (s *FileRouteStore) registerRoute(group *gin.RouterGroup, route *RouteDefin
 				group.HEAD(route.Path, handler)
 			case "OPTIONS":
 				group.OPTIONS(route.Path, handler)
+			case "PROPFIND", "REPORT":
+				group.Handle(strings.ToUpper(method), route.Path, handler)
 			default:
 				log.Printf("Unsupported HTTP method: %s for route %s", method, route.Path)
 			}
@@ -0,0 +1,75 @@
+package tasks
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/goatkit/goatflow/internal/config"
+	"github.com/goatkit/goatflow/internal/runner"
+	"github.com/goatkit/goatflow/internal/storage"
+)
+
+// ArticleCompressionTask compresses stored article bodies and attachments
+// once they reach the configured age, and recompresses values written with
+// another codec.
+type ArticleCompressionTask struct {
+	recompressor *storage.Recompressor
+	schedule     string
+	logger       *log.Logger
+}
+
+// NewArticleCompressionTask creates a new article compression task.
+func NewArticleCompressionTask(db *sql.DB, cfg *config.StorageCompressionConfig) runner.Task {
+	logger := log.New(log.Writer(), "[ARTICLE-COMPRESSION] ", log.LstdFlags)
+	compression, err := storage.CompressionFromConfig(*cfg)
+	if err != nil {
+		logger.Printf("Compression disabled: %v", err)
+		compression = &storage.Compression{}
+	}
+	r := storage.NewRecompressor(db, compression)
+	if cfg.BatchSize > 0 {
+		r.BatchSize = cfg.BatchSize
+	}
+	r.MaxRows = cfg.MaxRowsPerRun
+	schedule := cfg.Schedule
+	if schedule == "" {
+		schedule = "0 30 2 * * *"
+	}
+	return &ArticleCompressionTask{
+		recompressor: r,
+		schedule:     schedule,
+		logger:       logger,
+	}
+}
+
+// Name returns the task name.
+func (t *ArticleCompressionTask) Name() string {
+	return "article-compression"
+}
+
+// Schedule returns the cron schedule (storage.compression.schedule, daily
+// at 02:30 by default).
+func (t *ArticleCompressionTask) Schedule() string {
+	return t.schedule
+}
+
+// Timeout returns the task timeout (1 hour).
+func (t *ArticleCompressionTask) Timeout() time.Duration {
+	return time.Hour
+}
+
+// Run compresses the next batch of eligible rows.
+func (t *ArticleCompressionTask) Run(ctx context.Context) error {
+	c := t.recompressor.Compression
+	if !c.Body.Enabled && !c.Attachment.Enabled {
+		return nil
+	}
+	stats, err := t.recompressor.Run(ctx)
+	if stats != nil && stats.Scanned > 0 {
+		t.logger.Printf("Scanned %d rows: %d compressed (%d -> %d bytes), %d skipped, %d failed",
+			stats.Scanned, stats.Changed, stats.BytesBefore, stats.BytesAfter, stats.Skipped, stats.Failed)
+	}
+	return err
+}
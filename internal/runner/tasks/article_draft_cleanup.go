@@ -0,0 +1,50 @@
+package tasks
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/goatkit/goatflow/internal/runner"
+	"github.com/goatkit/goatflow/internal/service"
+)
+
+// ArticleDraftCleanupTask removes article drafts unchanged for longer
+// than the configured retention.
+type ArticleDraftCleanupTask struct {
+	svc    *service.ArticleDraftService
+	logger *log.Logger
+}
+
+// NewArticleDraftCleanupTask creates a new article draft cleanup task.
+func NewArticleDraftCleanupTask(db *sql.DB) runner.Task {
+	return &ArticleDraftCleanupTask{
+		svc:    service.NewArticleDraftService(db),
+		logger: log.New(log.Writer(), "[ARTICLE-DRAFT-CLEANUP] ", log.LstdFlags),
+	}
+}
+
+// Name returns the task name.
+func (t *ArticleDraftCleanupTask) Name() string {
+	return "article-draft-cleanup"
+}
+
+// Schedule returns the cron schedule (every hour at minute 35).
+func (t *ArticleDraftCleanupTask) Schedule() string {
+	return "0 35 * * * *"
+}
+
+// Timeout returns the task timeout (5 minutes).
+func (t *ArticleDraftCleanupTask) Timeout() time.Duration {
+	return 5 * time.Minute
+}
+
+// Run deletes the expired drafts.
+func (t *ArticleDraftCleanupTask) Run(ctx context.Context) error {
+	n, err := t.svc.PurgeExpired(ctx)
+	if n > 0 {
+		t.logger.Printf("Removed %d expired article draft(s)", n)
+	}
+	return err
+}
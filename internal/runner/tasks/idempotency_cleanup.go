@@ -0,0 +1,49 @@
+package tasks
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/goatkit/goatflow/internal/runner"
+	"github.com/goatkit/goatflow/internal/service"
+)
+
+// IdempotencyCleanupTask removes expired Idempotency-Key responses.
+type IdempotencyCleanupTask struct {
+	svc    *service.IdempotencyService
+	logger *log.Logger
+}
+
+// NewIdempotencyCleanupTask creates a new idempotency cleanup task.
+func NewIdempotencyCleanupTask(db *sql.DB) runner.Task {
+	return &IdempotencyCleanupTask{
+		svc:    service.NewIdempotencyService(db, 0),
+		logger: log.New(log.Writer(), "[IDEMPOTENCY-CLEANUP] ", log.LstdFlags),
+	}
+}
+
+// Name returns the task name.
+func (t *IdempotencyCleanupTask) Name() string {
+	return "idempotency-cleanup"
+}
+
+// Schedule returns the cron schedule (every hour at minute 20).
+func (t *IdempotencyCleanupTask) Schedule() string {
+	return "0 20 * * * *"
+}
+
+// Timeout returns the task timeout (5 minutes).
+func (t *IdempotencyCleanupTask) Timeout() time.Duration {
+	return 5 * time.Minute
+}
+
+// Run deletes every expired idempotency key.
+func (t *IdempotencyCleanupTask) Run(ctx context.Context) error {
+	n, err := t.svc.PurgeExpired(ctx)
+	if n > 0 {
+		t.logger.Printf("Removed %d expired idempotency key(s)", n)
+	}
+	return err
+}
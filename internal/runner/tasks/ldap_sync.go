@@ -0,0 +1,69 @@
+package tasks
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/goatkit/goatflow/internal/config"
+	"github.com/goatkit/goatflow/internal/ldap"
+	"github.com/goatkit/goatflow/internal/runner"
+	"github.com/goatkit/goatflow/internal/service"
+)
+
+// LDAPSyncTask copies user attributes and group memberships from the
+// configured LDAP directories into users and customer_user.
+type LDAPSyncTask struct {
+	accounts *service.LDAPAccountService
+	schedule string
+	logger   *log.Logger
+}
+
+// NewLDAPSyncTask creates a new LDAP sync task.
+func NewLDAPSyncTask(db *sql.DB, cfg *config.AuthConfig) runner.Task {
+	logger := log.New(log.Writer(), "[LDAP-SYNC] ", log.LstdFlags)
+	dirs, problems := ldap.DirectoriesFromConfig(cfg.LDAP.Directories)
+	for _, p := range problems {
+		logger.Printf("Skipping directory %s", p)
+	}
+	schedule := cfg.LDAP.SyncSchedule
+	if schedule == "" {
+		schedule = "0 */15 * * * *"
+	}
+	return &LDAPSyncTask{
+		accounts: service.NewLDAPAccountService(db, dirs),
+		schedule: schedule,
+		logger:   logger,
+	}
+}
+
+// Name returns the task name.
+func (t *LDAPSyncTask) Name() string {
+	return "ldap-sync"
+}
+
+// Schedule returns the cron schedule (auth.ldap.sync_schedule, every
+// 15 minutes by default).
+func (t *LDAPSyncTask) Schedule() string {
+	return t.schedule
+}
+
+// Timeout returns the task timeout (10 minutes).
+func (t *LDAPSyncTask) Timeout() time.Duration {
+	return 10 * time.Minute
+}
+
+// Run syncs every directory that has sync enabled.
+func (t *LDAPSyncTask) Run(ctx context.Context) error {
+	result, err := t.accounts.Sync(ctx)
+	if result == nil || result.Directories == 0 {
+		return err
+	}
+	for _, e := range result.Errors {
+		t.logger.Printf("Sync error: %s", e)
+	}
+	t.logger.Printf("Synced %d directories: %d users found, %d created, %d updated, %d skipped",
+		result.Directories, result.UsersFound, result.Created, result.Updated, result.Skipped)
+	return err
+}
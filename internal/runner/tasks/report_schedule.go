@@ -0,0 +1,72 @@
+package tasks
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/goatkit/goatflow/internal/config"
+	"github.com/goatkit/goatflow/internal/mailqueue"
+	"github.com/goatkit/goatflow/internal/repository"
+	"github.com/goatkit/goatflow/internal/runner"
+	"github.com/goatkit/goatflow/internal/service"
+)
+
+// ReportScheduleTask queues scheduled reports for email delivery.
+type ReportScheduleTask struct {
+	reportSvc *service.ReportService
+	queue     *mailqueue.MailQueueRepository
+	cfg       *config.EmailConfig
+	logger    *log.Logger
+}
+
+// NewReportScheduleTask creates a new report schedule task.
+func NewReportScheduleTask(db *sql.DB, cfg *config.EmailConfig) runner.Task {
+	return &ReportScheduleTask{
+		reportSvc: service.NewReportService(repository.NewReportRepository(db)),
+		queue:     mailqueue.NewMailQueueRepository(db),
+		cfg:       cfg,
+		logger:    log.New(log.Writer(), "[REPORT-SCHEDULE] ", log.LstdFlags),
+	}
+}
+
+// Name returns the task name.
+func (t *ReportScheduleTask) Name() string {
+	return "report-schedule"
+}
+
+// Schedule returns the cron schedule (every minute, matching the finest
+// granularity of report schedules).
+func (t *ReportScheduleTask) Schedule() string {
+	return "0 * * * * *"
+}
+
+// Timeout returns the task timeout (5 minutes).
+func (t *ReportScheduleTask) Timeout() time.Duration {
+	return 5 * time.Minute
+}
+
+// Run queues every scheduled report that is due.
+func (t *ReportScheduleTask) Run(ctx context.Context) error {
+	if t.cfg == nil || !t.cfg.Enabled {
+		return nil
+	}
+
+	defs, err := t.reportSvc.ListScheduled(ctx)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, def := range service.DueReports(defs, now) {
+		def := def
+		queued, err := t.reportSvc.SendScheduled(ctx, t.queue, &def, now, t.cfg.From)
+		if err != nil {
+			t.logger.Printf("Failed to send report %d (%s): %v", def.ID, def.Name, err)
+			continue
+		}
+		t.logger.Printf("Queued report %d (%s) for %d recipients", def.ID, def.Name, queued)
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package tasks
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/goatkit/goatflow/internal/config"
+	"github.com/goatkit/goatflow/internal/runner"
+	"github.com/goatkit/goatflow/internal/service"
+)
+
+// RequestCaptureCleanupTask removes captured requests and their replays
+// once they are older than the configured retention.
+type RequestCaptureCleanupTask struct {
+	svc    *service.RequestCaptureService
+	logger *log.Logger
+}
+
+// NewRequestCaptureCleanupTask creates a new request capture cleanup task.
+func NewRequestCaptureCleanupTask(db *sql.DB, cfg *config.RequestCaptureConfig) runner.Task {
+	return &RequestCaptureCleanupTask{
+		svc: service.NewRequestCaptureService(db, service.RequestCaptureOptions{
+			Retention: cfg.Retention,
+		}),
+		logger: log.New(log.Writer(), "[REQUEST-CAPTURE-CLEANUP] ", log.LstdFlags),
+	}
+}
+
+// Name returns the task name.
+func (t *RequestCaptureCleanupTask) Name() string {
+	return "request-capture-cleanup"
+}
+
+// Schedule returns the cron schedule (every hour at minute 25).
+func (t *RequestCaptureCleanupTask) Schedule() string {
+	return "0 25 * * * *"
+}
+
+// Timeout returns the task timeout (5 minutes).
+func (t *RequestCaptureCleanupTask) Timeout() time.Duration {
+	return 5 * time.Minute
+}
+
+// Run deletes captured requests past the retention period.
+func (t *RequestCaptureCleanupTask) Run(ctx context.Context) error {
+	n, err := t.svc.PurgeExpired(ctx)
+	if n > 0 {
+		t.logger.Printf("Removed %d expired captured request(s)", n)
+	}
+	return err
+}
@@ -0,0 +1,58 @@
+package tasks
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/goatkit/goatflow/internal/history"
+	"github.com/goatkit/goatflow/internal/repository"
+	"github.com/goatkit/goatflow/internal/runner"
+	"github.com/goatkit/goatflow/internal/service"
+)
+
+// TicketUnlockTask releases ticket locks whose TTL has passed.
+type TicketUnlockTask struct {
+	lockSvc  *service.TicketLockService
+	recorder *history.Recorder
+	logger   *log.Logger
+}
+
+// NewTicketUnlockTask creates a new ticket unlock task.
+func NewTicketUnlockTask(db *sql.DB) runner.Task {
+	return &TicketUnlockTask{
+		lockSvc:  service.NewTicketLockService(db, 0),
+		recorder: history.NewRecorder(repository.NewTicketRepository(db)),
+		logger:   log.New(log.Writer(), "[TICKET-UNLOCK] ", log.LstdFlags),
+	}
+}
+
+// Name returns the task name.
+func (t *TicketUnlockTask) Name() string {
+	return "ticket-unlock"
+}
+
+// Schedule returns the cron schedule (every minute).
+func (t *TicketUnlockTask) Schedule() string {
+	return "0 * * * * *"
+}
+
+// Timeout returns the task timeout (1 minute).
+func (t *TicketUnlockTask) Timeout() time.Duration {
+	return time.Minute
+}
+
+// Run unlocks every ticket whose lock has expired.
+func (t *TicketUnlockTask) Run(ctx context.Context) error {
+	unlocked, err := t.lockSvc.UnlockExpired(ctx)
+	for _, l := range unlocked {
+		if herr := t.recorder.RecordByTicketID(ctx, nil, l.TicketID, nil, history.TypeUnlock, "Lock expired", 1); herr != nil {
+			t.logger.Printf("Failed to record unlock of ticket %d: %v", l.TicketID, herr)
+		}
+	}
+	if len(unlocked) > 0 {
+		t.logger.Printf("Unlocked %d ticket(s) with expired locks", len(unlocked))
+	}
+	return err
+}
@@ -9,6 +9,7 @@ import (
 	"io"
 	"net/http"
 	"os"
+	"strings"
 	"time"
 )
 
@@ -127,7 +128,14 @@ func (eb *ElasticBackend) Search(ctx context.Context, query SearchQuery) (*Searc
 		return nil, err
 	}
 
-	req, err := http.NewRequestWithContext(ctx, "POST", fmt.Sprintf("%s/_search", eb.endpoint), bytes.NewBuffer(body))
+	// Route to the live partitions only unless archived results were requested.
+	searchURL := fmt.Sprintf("%s/_search", eb.endpoint)
+	if len(query.Types) > 0 {
+		indices := SearchIndices(query.Types, query.IncludeArchived)
+		searchURL = fmt.Sprintf("%s/%s/_search?ignore_unavailable=true", eb.endpoint, strings.Join(indices, ","))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", searchURL, bytes.NewBuffer(body))
 	if err != nil {
 		return nil, err
 	}
@@ -188,7 +196,7 @@ func (eb *ElasticBackend) Index(ctx context.Context, doc Document) error {
 		return err
 	}
 
-	url := fmt.Sprintf("%s/%s/_doc/%s", eb.endpoint, doc.Type, doc.ID)
+	url := fmt.Sprintf("%s/%s/_doc/%s", eb.endpoint, IndexName(doc.Type, doc.IsArchived()), doc.ID)
 	req, err := http.NewRequestWithContext(ctx, "PUT", url, bytes.NewBuffer(body))
 	if err != nil {
 		return err
@@ -208,12 +216,24 @@ func (eb *ElasticBackend) Index(ctx context.Context, doc Document) error {
 		return fmt.Errorf("indexing failed: %s", string(bodyBytes))
 	}
 
-	return nil
+	// Drop the copy in the other partition so archiving moves the document.
+	return eb.deleteFromIndex(ctx, IndexName(doc.Type, !doc.IsArchived()), doc.ID)
 }
 
 // Delete removes a document from Elasticsearch/Zinc.
+// Both the live and the archive partition are cleared since callers do not
+// know which one currently holds the document.
 func (eb *ElasticBackend) Delete(ctx context.Context, docType string, id string) error {
-	url := fmt.Sprintf("%s/%s/_doc/%s", eb.endpoint, docType, id)
+	for _, archived := range []bool{false, true} {
+		if err := eb.deleteFromIndex(ctx, IndexName(docType, archived), id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (eb *ElasticBackend) deleteFromIndex(ctx context.Context, index string, id string) error {
+	url := fmt.Sprintf("%s/%s/_doc/%s", eb.endpoint, index, id)
 	req, err := http.NewRequestWithContext(ctx, "DELETE", url, nil)
 	if err != nil {
 		return err
@@ -240,10 +260,21 @@ func (eb *ElasticBackend) BulkIndex(ctx context.Context, docs []Document) error
 	var bulkBody bytes.Buffer
 
 	for _, doc := range docs {
+		// Remove the copy in the other partition before indexing
+		deleteAction := map[string]interface{}{
+			"delete": map[string]interface{}{
+				"_index": IndexName(doc.Type, !doc.IsArchived()),
+				"_id":    doc.ID,
+			},
+		}
+		deleteBytes, _ := json.Marshal(deleteAction)
+		bulkBody.Write(deleteBytes)
+		bulkBody.WriteByte('\n')
+
 		// Index action
 		action := map[string]interface{}{
 			"index": map[string]interface{}{
-				"_index": doc.Type,
+				"_index": IndexName(doc.Type, doc.IsArchived()),
 				"_id":    doc.ID,
 			},
 		}
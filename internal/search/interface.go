@@ -37,6 +37,33 @@ type SearchQuery struct {
 	SortOrder string            `json:"sort_order"` // asc or desc
 	Highlight bool              `json:"highlight"`  // Enable result highlighting
 	Facets    []string          `json:"facets"`     // Fields to generate facets for
+
+	// IncludeArchived opts into searching archived tickets. Archived data lives
+	// in its own index partition (see IndexName) so live searches never scan it.
+	IncludeArchived bool `json:"include_archived"`
+}
+
+// ArchiveIndexSuffix is appended to the index name of archived documents.
+const ArchiveIndexSuffix = "_archive"
+
+// IndexName returns the index partition for a document type.
+func IndexName(docType string, archived bool) string {
+	if archived {
+		return docType + ArchiveIndexSuffix
+	}
+	return docType
+}
+
+// SearchIndices returns the index partitions a query must hit for the given types.
+func SearchIndices(types []string, includeArchived bool) []string {
+	indices := make([]string, 0, len(types)*2)
+	for _, t := range types {
+		indices = append(indices, IndexName(t, false))
+		if includeArchived {
+			indices = append(indices, IndexName(t, true))
+		}
+	}
+	return indices
 }
 
 // Document represents a searchable document.
@@ -50,6 +77,12 @@ type Document struct {
 	ModifiedAt time.Time              `json:"modified_at"`
 }
 
+// IsArchived reports whether the document belongs to an archived ticket.
+func (d Document) IsArchived() bool {
+	archived, _ := d.Metadata["archived"].(bool)
+	return archived
+}
+
 // SearchResults contains search results.
 type SearchResults struct {
 	Query       string             `json:"query"`
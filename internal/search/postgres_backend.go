@@ -106,6 +106,11 @@ func (pb *PostgresBackend) searchTickets(ctx context.Context, query SearchQuery)
 
 	args := []interface{}{query.Query}
 
+	// Archived tickets are only scanned on explicit opt-in
+	if !query.IncludeArchived {
+		sqlQuery += " AND t.archive_flag = 0"
+	}
+
 	// Add filters
 	if queueFilter, ok := query.Filters["queue_id"]; ok {
 		sqlQuery += " AND t.queue_id = ?"
@@ -117,6 +122,11 @@ func (pb *PostgresBackend) searchTickets(ctx context.Context, query SearchQuery)
 		args = append(args, stateFilter)
 	}
 
+	if typeFilter, ok := query.Filters["type_id"]; ok {
+		sqlQuery += " AND t.type_id = ?"
+		args = append(args, typeFilter)
+	}
+
 	sqlQuery += `
 		GROUP BY t.id, t.tn, t.title, t.create_time, q.name, s.name, p.name
 		ORDER BY score DESC
@@ -178,22 +188,32 @@ func (pb *PostgresBackend) searchTickets(ctx context.Context, query SearchQuery)
 	return hits, nil
 }
 
-// searchArticles searches for articles.
+// searchArticles searches for articles. Email replies are matched on their
+// visible text, without the earlier messages they quote.
 func (pb *PostgresBackend) searchArticles(ctx context.Context, query SearchQuery) ([]SearchHit, error) {
 	sqlQuery := database.ConvertPlaceholders(`
 		SELECT 
-			a.id, a.subject, a.body,
-			ts_rank(to_tsvector('english', a.subject || ' ' || a.body), plainto_tsquery('english', ?)) as score,
+			a.id, COALESCE(adm.a_subject, ''), COALESCE(avt.visible_text, adm.a_body, ''),
+			ts_rank(to_tsvector('english', COALESCE(adm.a_subject, '') || ' ' || COALESCE(avt.visible_text, adm.a_body, '')),
+				plainto_tsquery('english', ?)) as score,
 			a.create_time,
 			t.tn as ticket_number,
 			t.title as ticket_title
 		FROM article a
-		JOIN tickets t ON a.ticket_id = t.id
-		WHERE to_tsvector('english', a.subject || ' ' || a.body) @@ plainto_tsquery('english', ?)
-		ORDER BY score DESC
+		JOIN article_data_mime adm ON adm.article_id = a.id
+		LEFT JOIN article_visible_text avt ON avt.article_id = a.id
+		JOIN ticket t ON a.ticket_id = t.id
+		WHERE to_tsvector('english', COALESCE(adm.a_subject, '') || ' ' || COALESCE(avt.visible_text, adm.a_body, ''))
+			@@ plainto_tsquery('english', ?)
 	`)
+	if !query.IncludeArchived {
+		sqlQuery += " AND t.archive_flag = 0"
+	}
+	sqlQuery += `
+		ORDER BY score DESC
+	`
 
-	rows, err := pb.db.QueryContext(ctx, sqlQuery, query.Query)
+	rows, err := pb.db.QueryContext(ctx, sqlQuery, query.Query, query.Query)
 	if err != nil {
 		return nil, err
 	}
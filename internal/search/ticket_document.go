@@ -0,0 +1,42 @@
+package search
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/goatkit/goatflow/internal/models"
+)
+
+// TicketDocument builds the search document for a ticket. Archived tickets
+// are marked in Metadata["archived"] so backends index them into the archive
+// partition (see IndexName).
+func TicketDocument(t *models.Ticket) Document {
+	return Document{
+		ID:    strconv.Itoa(t.ID),
+		Type:  "ticket",
+		Title: t.Title,
+		Metadata: map[string]interface{}{
+			"ticket_number":      t.TicketNumber,
+			"queue_id":           t.QueueID,
+			"ticket_state_id":    t.TicketStateID,
+			"ticket_priority_id": t.TicketPriorityID,
+			"archived":           t.IsArchived(),
+		},
+		CreatedAt:  t.CreateTime,
+		ModifiedAt: t.ChangeTime,
+	}
+}
+
+// IndexTicket indexes a ticket's TicketDocument into every registered
+// backend, so an archived or restored ticket moves to the matching
+// partition.
+func (sm *SearchManager) IndexTicket(ctx context.Context, ticket *models.Ticket) error {
+	doc := TicketDocument(ticket)
+	for name, backend := range sm.backends {
+		if err := backend.Index(ctx, doc); err != nil {
+			return fmt.Errorf("index ticket %d in %s: %w", ticket.ID, name, err)
+		}
+	}
+	return nil
+}
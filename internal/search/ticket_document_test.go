@@ -0,0 +1,135 @@
+package search
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/goatkit/goatflow/internal/models"
+)
+
+// fakeElastic is an Elasticsearch stand-in keeping documents per index. A
+// search returns every document of the requested indices.
+type fakeElastic struct {
+	mu      sync.Mutex
+	indices map[string]map[string]map[string]interface{}
+}
+
+func (f *fakeElastic) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	switch {
+	case len(parts) == 3 && parts[1] == "_doc" && r.Method == http.MethodPut:
+		var doc map[string]interface{}
+		if err := json.NewDecoder(r.Body).Decode(&doc); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if f.indices[parts[0]] == nil {
+			f.indices[parts[0]] = map[string]map[string]interface{}{}
+		}
+		f.indices[parts[0]][parts[2]] = doc
+		w.WriteHeader(http.StatusCreated)
+	case len(parts) == 3 && parts[1] == "_doc" && r.Method == http.MethodDelete:
+		delete(f.indices[parts[0]], parts[2])
+	case len(parts) == 2 && parts[1] == "_search":
+		var resp ElasticSearchResponse
+		for _, index := range strings.Split(parts[0], ",") {
+			for id, doc := range f.indices[index] {
+				resp.Hits.Hits = append(resp.Hits.Hits, struct {
+					ID        string                 `json:"_id"`
+					Score     float64                `json:"_score"`
+					Source    map[string]interface{} `json:"_source"`
+					Highlight map[string][]string    `json:"highlight,omitempty"`
+				}{ID: id, Score: 1, Source: doc})
+			}
+		}
+		resp.Hits.Total.Value = len(resp.Hits.Hits)
+		_ = json.NewEncoder(w).Encode(resp)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestTicketDocument_MarksArchivedTickets(t *testing.T) {
+	if doc := TicketDocument(&models.Ticket{ID: 1}); doc.IsArchived() {
+		t.Error("a live ticket should not be marked archived")
+	}
+	if doc := TicketDocument(&models.Ticket{ID: 2, ArchiveFlag: 1}); !doc.IsArchived() {
+		t.Error("an archived ticket should be marked archived")
+	}
+}
+
+func TestElasticBackend_ArchivedTicketOnlyInArchivePartition(t *testing.T) {
+	es := &fakeElastic{indices: map[string]map[string]map[string]interface{}{}}
+	srv := httptest.NewServer(es)
+	defer srv.Close()
+	eb := NewElasticBackend(srv.URL, "u", "p")
+	ctx := context.Background()
+
+	ticket := &models.Ticket{ID: 42, TicketNumber: "2024010100042", Title: "Old printer issue", ArchiveFlag: 1}
+	if err := eb.Index(ctx, TicketDocument(ticket)); err != nil {
+		t.Fatalf("Index: %v", err)
+	}
+	if _, ok := es.indices["ticket_archive"]["42"]; !ok {
+		t.Fatalf("expected the ticket in the archive partition, indices: %v", es.indices)
+	}
+	if _, ok := es.indices["ticket"]["42"]; ok {
+		t.Error("an archived ticket should not be in the live partition")
+	}
+
+	live, err := eb.Search(ctx, SearchQuery{Query: "printer", Types: []string{"ticket"}})
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if live.TotalHits != 0 {
+		t.Errorf("live search found %d hits, want the archived ticket left out", live.TotalHits)
+	}
+	all, err := eb.Search(ctx, SearchQuery{Query: "printer", Types: []string{"ticket"}, IncludeArchived: true})
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if all.TotalHits != 1 || all.Hits[0].ID != "42" {
+		t.Errorf("archive search = %+v, want the archived ticket", all.Hits)
+	}
+}
+
+func TestSearchManager_IndexTicketFollowsArchiveState(t *testing.T) {
+	es := &fakeElastic{indices: map[string]map[string]map[string]interface{}{}}
+	srv := httptest.NewServer(es)
+	defer srv.Close()
+	sm := NewSearchManager()
+	sm.RegisterBackend("elasticsearch", NewElasticBackend(srv.URL, "u", "p"), true)
+	ctx := context.Background()
+
+	ticket := &models.Ticket{ID: 7, TicketNumber: "2024010100007", Title: "Archive me"}
+	if err := sm.IndexTicket(ctx, ticket); err != nil {
+		t.Fatalf("IndexTicket: %v", err)
+	}
+	ticket.ArchiveFlag = 1
+	if err := sm.IndexTicket(ctx, ticket); err != nil {
+		t.Fatalf("IndexTicket: %v", err)
+	}
+	if _, ok := es.indices["ticket"]["7"]; ok {
+		t.Error("an archived ticket should leave the live partition")
+	}
+	if _, ok := es.indices["ticket_archive"]["7"]; !ok {
+		t.Errorf("expected the archived ticket in the archive partition, indices: %v", es.indices)
+	}
+
+	ticket.ArchiveFlag = 0
+	if err := sm.IndexTicket(ctx, ticket); err != nil {
+		t.Fatalf("IndexTicket: %v", err)
+	}
+	if _, ok := es.indices["ticket_archive"]["7"]; ok {
+		t.Error("a restored ticket should leave the archive partition")
+	}
+	if _, ok := es.indices["ticket"]["7"]; !ok {
+		t.Error("expected the restored ticket back in the live partition")
+	}
+}
@@ -0,0 +1,119 @@
+package secrets
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// KeyProvider supplies the master keys: the current one, which encrypts,
+// and previous ones still needed to decrypt until `gk secrets rotate` ran.
+// A nil current key means encryption is not configured.
+type KeyProvider interface {
+	MasterKeys() (current []byte, previous [][]byte, err error)
+}
+
+// EnvKeyProvider reads the master key from SECRETS_MASTER_KEY and previous
+// ones from the comma-separated SECRETS_MASTER_KEY_PREVIOUS, like the other
+// secrets of a deployment. Either may instead name a file with the _FILE
+// suffix, for Docker and Kubernetes secrets. Keys are 64 hex characters or
+// 32 bytes in base64.
+type EnvKeyProvider struct{}
+
+// MasterKeys implements KeyProvider.
+func (EnvKeyProvider) MasterKeys() ([]byte, [][]byte, error) {
+	current, err := envValue("SECRETS_MASTER_KEY")
+	if err != nil || current == "" {
+		return nil, nil, err
+	}
+	key, err := ParseKey(current)
+	if err != nil {
+		return nil, nil, fmt.Errorf("SECRETS_MASTER_KEY: %w", err)
+	}
+	prev, err := envValue("SECRETS_MASTER_KEY_PREVIOUS")
+	if err != nil {
+		return nil, nil, err
+	}
+	var previous [][]byte
+	for _, s := range strings.Split(prev, ",") {
+		if s = strings.TrimSpace(s); s == "" {
+			continue
+		}
+		k, err := ParseKey(s)
+		if err != nil {
+			return nil, nil, fmt.Errorf("SECRETS_MASTER_KEY_PREVIOUS: %w", err)
+		}
+		previous = append(previous, k)
+	}
+	return key, previous, nil
+}
+
+func envValue(name string) (string, error) {
+	if v := os.Getenv(name); v != "" {
+		return strings.TrimSpace(v), nil
+	}
+	path := os.Getenv(name + "_FILE")
+	if path == "" {
+		return "", nil
+	}
+	b, err := os.ReadFile(path) //nolint:gosec // G304 - path comes from the operator's environment
+	if err != nil {
+		return "", fmt.Errorf("read %s_FILE: %w", name, err)
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+// ParseKey decodes a master key given as hex or base64.
+func ParseKey(s string) ([]byte, error) {
+	if len(s) == 64 {
+		if b, err := hex.DecodeString(s); err == nil {
+			return b, nil
+		}
+	}
+	for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.RawStdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+		if b, err := enc.DecodeString(s); err == nil && len(b) == 32 {
+			return b, nil
+		}
+	}
+	return nil, fmt.Errorf("master key must be 32 bytes as 64 hex characters or base64")
+}
+
+var (
+	providerMu sync.Mutex
+	provider   KeyProvider = EnvKeyProvider{}
+	keyring    *Keyring
+	loaded     bool
+)
+
+// SetKeyProvider replaces where master keys come from, e.g. with a vault
+// client. The keyring is loaded again on next use.
+func SetKeyProvider(p KeyProvider) {
+	providerMu.Lock()
+	defer providerMu.Unlock()
+	provider, keyring, loaded = p, nil, false
+}
+
+// Default returns the keyring of the configured master keys, loading them
+// once. It is nil when no master key is configured, so values are stored
+// in plaintext.
+func Default() (*Keyring, error) {
+	providerMu.Lock()
+	defer providerMu.Unlock()
+	if loaded {
+		return keyring, nil
+	}
+	current, previous, err := provider.MasterKeys()
+	if err != nil {
+		return nil, err
+	}
+	if current != nil {
+		if keyring, err = NewKeyring(current, previous...); err != nil {
+			return nil, err
+		}
+	}
+	loaded = true
+	return keyring, nil
+}
@@ -0,0 +1,223 @@
+// Package secrets encrypts credentials stored in the database with envelope
+// encryption: every value gets its own AES-256-GCM data key, which is itself
+// encrypted with a master key from the key provider. Rotating the master key
+// only re-encrypts the data keys.
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Prefix marks encrypted values; values without it are plaintext.
+const Prefix = "enc:v1:"
+
+var (
+	// ErrNoKey is returned when an encrypted value is read while no master
+	// key is configured.
+	ErrNoKey = errors.New("secrets: no master key configured")
+	// ErrUnknownKey is returned when a value was encrypted with a master key
+	// that is neither the current nor a previous one.
+	ErrUnknownKey = errors.New("secrets: value was encrypted with an unknown master key")
+	// ErrMalformed is returned for encrypted values that cannot be decoded
+	// or fail authentication.
+	ErrMalformed = errors.New("secrets: malformed encrypted value")
+)
+
+type masterKey struct {
+	id   string
+	aead cipher.AEAD
+}
+
+// Keyring holds the current master key, which encrypts, and previous ones,
+// which only decrypt. A nil Keyring stores values in plaintext.
+type Keyring struct {
+	current *masterKey
+	keys    map[string]*masterKey
+}
+
+// NewKeyring creates a keyring from 32-byte master keys.
+func NewKeyring(current []byte, previous ...[]byte) (*Keyring, error) {
+	k := &Keyring{keys: map[string]*masterKey{}}
+	for i, raw := range append([][]byte{current}, previous...) {
+		mk, err := newMasterKey(raw)
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			k.current = mk
+		}
+		if _, ok := k.keys[mk.id]; !ok {
+			k.keys[mk.id] = mk
+		}
+	}
+	return k, nil
+}
+
+func newMasterKey(raw []byte) (*masterKey, error) {
+	if len(raw) != 32 {
+		return nil, fmt.Errorf("secrets: master key must be 32 bytes, got %d", len(raw))
+	}
+	aead, err := newAEAD(raw)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(raw)
+	return &masterKey{id: hex.EncodeToString(sum[:4]), aead: aead}, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// KeyID returns the ID of the current master key, as found in the values it
+// encrypts.
+func (k *Keyring) KeyID() string {
+	if k == nil {
+		return ""
+	}
+	return k.current.id
+}
+
+// IsEncrypted reports whether a stored value is encrypted.
+func IsEncrypted(value string) bool {
+	return strings.HasPrefix(value, Prefix)
+}
+
+// Encrypt encrypts a value with a new data key under the current master
+// key. Empty values stay empty, and a nil keyring returns the plaintext.
+func (k *Keyring) Encrypt(plaintext string) (string, error) {
+	if k == nil || plaintext == "" || IsEncrypted(plaintext) {
+		return plaintext, nil
+	}
+	dataKey := make([]byte, 32)
+	if _, err := rand.Read(dataKey); err != nil {
+		return "", fmt.Errorf("secrets: generate data key: %w", err)
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return "", err
+	}
+	data, err := seal(aead, []byte(plaintext), []byte(Prefix))
+	if err != nil {
+		return "", err
+	}
+	wrapped, err := seal(k.current.aead, dataKey, []byte(k.current.id))
+	if err != nil {
+		return "", err
+	}
+	return format(k.current.id, wrapped, data), nil
+}
+
+// Decrypt returns the plaintext of a stored value. Plaintext values, e.g.
+// written before a master key was configured, are returned unchanged.
+func (k *Keyring) Decrypt(value string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+	if k == nil {
+		return "", ErrNoKey
+	}
+	mk, wrapped, data, err := k.parse(value)
+	if err != nil {
+		return "", err
+	}
+	dataKey, err := open(mk.aead, wrapped, []byte(mk.id))
+	if err != nil {
+		return "", err
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return "", ErrMalformed
+	}
+	plaintext, err := open(aead, data, []byte(Prefix))
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// Rotate re-encrypts the data key of a value under the current master key,
+// and encrypts plaintext values. It reports whether the value changed.
+func (k *Keyring) Rotate(value string) (string, bool, error) {
+	if k == nil {
+		return value, false, ErrNoKey
+	}
+	if !IsEncrypted(value) {
+		enc, err := k.Encrypt(value)
+		return enc, enc != value, err
+	}
+	mk, wrapped, data, err := k.parse(value)
+	if err != nil {
+		return value, false, err
+	}
+	if mk == k.current {
+		return value, false, nil
+	}
+	dataKey, err := open(mk.aead, wrapped, []byte(mk.id))
+	if err != nil {
+		return value, false, err
+	}
+	rewrapped, err := seal(k.current.aead, dataKey, []byte(k.current.id))
+	if err != nil {
+		return value, false, err
+	}
+	return format(k.current.id, rewrapped, data), true, nil
+}
+
+// Values look like enc:v1:<key id>:<encrypted data key>:<encrypted value>.
+func format(keyID string, wrapped, data []byte) string {
+	return Prefix + keyID + ":" + base64.RawStdEncoding.EncodeToString(wrapped) + ":" +
+		base64.RawStdEncoding.EncodeToString(data)
+}
+
+func (k *Keyring) parse(value string) (*masterKey, []byte, []byte, error) {
+	parts := strings.Split(strings.TrimPrefix(value, Prefix), ":")
+	if len(parts) != 3 {
+		return nil, nil, nil, ErrMalformed
+	}
+	mk, ok := k.keys[parts[0]]
+	if !ok {
+		return nil, nil, nil, fmt.Errorf("%w (key %s)", ErrUnknownKey, parts[0])
+	}
+	wrapped, err := base64.RawStdEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, nil, nil, ErrMalformed
+	}
+	data, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, nil, nil, ErrMalformed
+	}
+	return mk, wrapped, data, nil
+}
+
+func seal(aead cipher.AEAD, plaintext, additional []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("secrets: generate nonce: %w", err)
+	}
+	return aead.Seal(nonce, nonce, plaintext, additional), nil
+}
+
+func open(aead cipher.AEAD, sealed, additional []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, ErrMalformed
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	plaintext, err := aead.Open(nil, nonce, ciphertext, additional)
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	return plaintext, nil
+}
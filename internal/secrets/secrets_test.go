@@ -0,0 +1,109 @@
+package secrets
+
+import (
+	"bytes"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyring_EncryptDecrypt(t *testing.T) {
+	keys, err := NewKeyring(bytes.Repeat([]byte{1}, 32))
+	require.NoError(t, err)
+
+	enc, err := keys.Encrypt("s3cret")
+	require.NoError(t, err)
+	assert.True(t, IsEncrypted(enc))
+	assert.True(t, strings.HasPrefix(enc, Prefix+keys.KeyID()+":"))
+	assert.NotContains(t, enc, "s3cret")
+
+	again, err := keys.Encrypt("s3cret")
+	require.NoError(t, err)
+	assert.NotEqual(t, enc, again, "every value gets its own data key and nonce")
+
+	plain, err := keys.Decrypt(enc)
+	require.NoError(t, err)
+	assert.Equal(t, "s3cret", plain)
+
+	plain, err = keys.Decrypt("legacy plaintext")
+	require.NoError(t, err)
+	assert.Equal(t, "legacy plaintext", plain)
+
+	empty, err := keys.Encrypt("")
+	require.NoError(t, err)
+	assert.Empty(t, empty)
+
+	_, err = keys.Decrypt(enc[:len(enc)-2] + "AA")
+	assert.ErrorIs(t, err, ErrMalformed)
+
+	var none *Keyring
+	same, err := none.Encrypt("s3cret")
+	require.NoError(t, err)
+	assert.Equal(t, "s3cret", same)
+	_, err = none.Decrypt(enc)
+	assert.ErrorIs(t, err, ErrNoKey)
+}
+
+func TestKeyring_Rotate(t *testing.T) {
+	oldKey, newKey := bytes.Repeat([]byte{1}, 32), bytes.Repeat([]byte{2}, 32)
+	old, err := NewKeyring(oldKey)
+	require.NoError(t, err)
+	enc, err := old.Encrypt("s3cret")
+	require.NoError(t, err)
+
+	current, err := NewKeyring(newKey)
+	require.NoError(t, err)
+	_, err = current.Decrypt(enc)
+	assert.ErrorIs(t, err, ErrUnknownKey)
+
+	rotating, err := NewKeyring(newKey, oldKey)
+	require.NoError(t, err)
+	rotated, changed, err := rotating.Rotate(enc)
+	require.NoError(t, err)
+	assert.True(t, changed)
+	plain, err := current.Decrypt(rotated)
+	require.NoError(t, err)
+	assert.Equal(t, "s3cret", plain)
+
+	_, changed, err = rotating.Rotate(rotated)
+	require.NoError(t, err)
+	assert.False(t, changed, "already under the current key")
+
+	encrypted, changed, err := rotating.Rotate("plaintext")
+	require.NoError(t, err)
+	assert.True(t, changed)
+	assert.True(t, IsEncrypted(encrypted))
+}
+
+func TestEnvKeyProvider(t *testing.T) {
+	key := bytes.Repeat([]byte{7}, 32)
+	path := filepath.Join(t.TempDir(), "master.key")
+	require.NoError(t, os.WriteFile(path, []byte(hex.EncodeToString(key)+"\n"), 0o600))
+	t.Setenv("SECRETS_MASTER_KEY", "")
+	t.Setenv("SECRETS_MASTER_KEY_FILE", path)
+	t.Setenv("SECRETS_MASTER_KEY_PREVIOUS", "AgICAgICAgICAgICAgICAgICAgICAgICAgICAgICAgI=")
+
+	current, previous, err := EnvKeyProvider{}.MasterKeys()
+	require.NoError(t, err)
+	assert.Equal(t, key, current)
+	require.Len(t, previous, 1)
+	assert.Equal(t, bytes.Repeat([]byte{2}, 32), previous[0])
+
+	t.Setenv("SECRETS_MASTER_KEY", "too-short")
+	_, _, err = EnvKeyProvider{}.MasterKeys()
+	assert.Error(t, err)
+
+	SetKeyProvider(EnvKeyProvider{})
+	t.Cleanup(func() { SetKeyProvider(EnvKeyProvider{}) })
+	_, err = Default()
+	assert.Error(t, err)
+	t.Setenv("SECRETS_MASTER_KEY", hex.EncodeToString(key))
+	keys, err := Default()
+	require.NoError(t, err)
+	assert.NotNil(t, keys)
+}
@@ -0,0 +1,259 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/repository"
+)
+
+// ErrAgentStatsGrouping is returned for an unknown group_by value.
+var ErrAgentStatsGrouping = errors.New("group_by must be queue or team")
+
+const (
+	// AgentStatsDefaultWindow is the window used when none is requested.
+	AgentStatsDefaultWindow = "30d"
+	// AgentStatsGroup is the group whose members may view agent statistics,
+	// besides admins.
+	AgentStatsGroup = "stats"
+)
+
+// AgentStatsService computes agent performance statistics over windows of
+// completed days from the ticket history and accounted time.
+type AgentStatsService struct {
+	repo     *repository.AgentStatsRepository
+	access   *QueueAccessService
+	location *time.Location
+	now      func() time.Time
+}
+
+// NewAgentStatsService creates an agent statistics service working in UTC.
+func NewAgentStatsService(db *sql.DB) *AgentStatsService {
+	return &AgentStatsService{
+		repo:     repository.NewAgentStatsRepository(db),
+		access:   NewQueueAccessService(db),
+		location: time.UTC,
+		now:      time.Now,
+	}
+}
+
+// CanView reports whether a user may view agent statistics: admins and
+// members of the stats group, directly or through a role, may.
+func (s *AgentStatsService) CanView(ctx context.Context, userID int) (bool, error) {
+	if admin, err := s.access.IsAdmin(ctx, uint(userID)); err != nil || admin {
+		return admin, err
+	}
+	groupIDs, err := s.access.GetUserEffectiveGroupIDs(ctx, uint(userID), "ro")
+	if err != nil || len(groupIDs) == 0 {
+		return false, err
+	}
+	statsID, err := s.repo.GroupID(ctx, AgentStatsGroup)
+	if err != nil || statsID == 0 {
+		return false, err
+	}
+	for _, id := range groupIDs {
+		if int(id) == statsID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Stats computes the statistics of each agent over the completed days of a
+// window ending yesterday, such as "30d", optionally split by queue or team.
+//
+// A resolution is a ticket moving into a closed state, credited to its
+// owner at that moment; it counts as reopened when the ticket later left
+// the closed state. Handle time is the time agents accounted in the window
+// per ticket they accounted time on. SLA compliance is the percentage of
+// resolved tickets with a solution time target that were closed before
+// the target escalated.
+func (s *AgentStatsService) Stats(ctx context.Context, window string, groupBy models.AgentStatsGrouping) (*models.AgentStatsReport, error) {
+	if window == "" {
+		window = AgentStatsDefaultWindow
+	}
+	n, err := parseStatsWindow(window)
+	if err != nil {
+		return nil, err
+	}
+	if !groupBy.IsValid() {
+		return nil, ErrAgentStatsGrouping
+	}
+	now := s.now().In(s.location)
+	to := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, s.location)
+	from := to.AddDate(0, 0, -n)
+
+	resolutions, err := s.repo.Resolutions(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := s.repo.TimeEntries(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+	queues, err := s.repo.QueueTeams(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	type key struct{ agentID, groupID int }
+	type sums struct {
+		stats   models.AgentStats
+		minutes float64
+		tickets map[int64]bool
+	}
+	rows := make(map[key]*sums)
+	row := func(agentID, queueID int) *sums {
+		k := key{agentID: agentID}
+		switch groupBy {
+		case models.AgentStatsByQueue:
+			k.groupID = queueID
+		case models.AgentStatsByTeam:
+			k.groupID = queues[queueID].TeamID
+		}
+		r := rows[k]
+		if r == nil {
+			r = &sums{stats: models.AgentStats{AgentID: agentID}, tickets: make(map[int64]bool)}
+			switch groupBy {
+			case models.AgentStatsByQueue:
+				id := k.groupID
+				r.stats.QueueID, r.stats.QueueName = &id, queues[queueID].QueueName
+			case models.AgentStatsByTeam:
+				id := k.groupID
+				r.stats.TeamID, r.stats.TeamName = &id, queues[queueID].TeamName
+			}
+			rows[k] = r
+		}
+		return r
+	}
+
+	for _, res := range resolutions {
+		r := row(res.AgentID, res.QueueID)
+		r.stats.Resolved++
+		if res.Reopened {
+			r.stats.Reopened++
+		}
+		if res.HasSLA {
+			r.stats.SLATickets++
+			if res.SLAMet {
+				r.stats.SLAMet++
+			}
+		}
+	}
+	for _, e := range entries {
+		r := row(e.AgentID, e.QueueID)
+		r.minutes += e.Minutes
+		r.tickets[e.TicketID] = true
+	}
+
+	agentIDs := make([]int, 0, len(rows))
+	seen := make(map[int]bool)
+	for k := range rows {
+		if !seen[k.agentID] {
+			seen[k.agentID] = true
+			agentIDs = append(agentIDs, k.agentID)
+		}
+	}
+	agents, err := s.repo.Agents(ctx, agentIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &models.AgentStatsReport{
+		Window:  window,
+		From:    from.Format("2006-01-02"),
+		To:      to.AddDate(0, 0, -1).Format("2006-01-02"),
+		GroupBy: groupBy,
+		Agents:  make([]models.AgentStats, 0, len(rows)),
+	}
+	for _, r := range rows {
+		st := r.stats
+		st.AgentLogin, st.AgentName = agents[st.AgentID].Login, agents[st.AgentID].Name
+		st.HandledTickets = len(r.tickets)
+		if st.Resolved > 0 {
+			st.ReopenedRatio = roundedPtr(float64(st.Reopened)/float64(st.Resolved), 10000)
+		}
+		if st.HandledTickets > 0 {
+			st.AvgHandleTimeMinutes = roundedPtr(r.minutes/float64(st.HandledTickets), 100)
+		}
+		if st.SLATickets > 0 {
+			st.SLACompliance = roundedPtr(float64(st.SLAMet)*100/float64(st.SLATickets), 10)
+		}
+		report.Agents = append(report.Agents, st)
+	}
+	sort.Slice(report.Agents, func(i, j int) bool {
+		a, b := report.Agents[i], report.Agents[j]
+		if a.AgentLogin != b.AgentLogin {
+			return a.AgentLogin < b.AgentLogin
+		}
+		if a.AgentID != b.AgentID {
+			return a.AgentID < b.AgentID
+		}
+		return a.QueueName+a.TeamName < b.QueueName+b.TeamName
+	})
+	return report, nil
+}
+
+// RenderAgentStatsCSV renders agent statistics as CSV, one row per agent or
+// per agent and queue or team.
+func RenderAgentStatsCSV(report *models.AgentStatsReport) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	header := []string{"Agent ID", "Login", "Name"}
+	switch report.GroupBy {
+	case models.AgentStatsByQueue:
+		header = append(header, "Queue ID", "Queue")
+	case models.AgentStatsByTeam:
+		header = append(header, "Team ID", "Team")
+	}
+	header = append(header, "Resolved", "Reopened", "Reopened Ratio", "Handled Tickets",
+		"Avg Handle Time (min)", "SLA Tickets", "SLA Met", "SLA Compliance (%)")
+	if err := w.Write(header); err != nil {
+		return nil, err
+	}
+	for _, a := range report.Agents {
+		rec := []string{strconv.Itoa(a.AgentID), a.AgentLogin, a.AgentName}
+		switch report.GroupBy {
+		case models.AgentStatsByQueue:
+			rec = append(rec, optionalInt(a.QueueID), a.QueueName)
+		case models.AgentStatsByTeam:
+			rec = append(rec, optionalInt(a.TeamID), a.TeamName)
+		}
+		rec = append(rec, strconv.Itoa(a.Resolved), strconv.Itoa(a.Reopened), optionalFloat(a.ReopenedRatio),
+			strconv.Itoa(a.HandledTickets), optionalFloat(a.AvgHandleTimeMinutes),
+			strconv.Itoa(a.SLATickets), strconv.Itoa(a.SLAMet), optionalFloat(a.SLACompliance))
+		if err := w.Write(rec); err != nil {
+			return nil, err
+		}
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// roundedPtr returns v rounded to 1/scale.
+func roundedPtr(v, scale float64) *float64 {
+	r := math.Round(v*scale) / scale
+	return &r
+}
+
+func optionalInt(v *int) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.Itoa(*v)
+}
+
+func optionalFloat(v *float64) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*v, 'f', -1, 64)
+}
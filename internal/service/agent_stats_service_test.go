@@ -0,0 +1,149 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/testutil"
+)
+
+func TestAgentStatsService(t *testing.T) {
+	db := testutil.UseMigratedDB(t)
+	for _, stmt := range []string{
+		`INSERT INTO users (id, login, pw, first_name, last_name, valid_id, create_time, create_by, change_time, change_by) VALUES
+			(2, 'alice', 'x', 'Alice', 'Agent', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1),
+			(3, 'bob', 'x', 'Bob', 'Agent', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1),
+			(4, 'carol', 'x', 'Carol', 'Lead', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+		`INSERT INTO group_user (user_id, group_id, permission_key, create_time, create_by, change_time, change_by)
+			SELECT 4, id, 'ro', CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1 FROM groups WHERE name = 'stats'`,
+		// Queue 2 resolves tickets within an hour; queue 1 sets no target
+		`UPDATE queue SET solution_time = 60 WHERE id = 2`,
+		`UPDATE queue SET solution_time = 0 WHERE id <> 2`,
+	} {
+		_, err := db.Exec(stmt)
+		require.NoError(t, err, stmt)
+	}
+
+	now := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	day := func(daysAgo, hour int) time.Time {
+		return time.Date(2026, 10, 16-daysAgo, hour, 0, 0, 0, time.UTC)
+	}
+	addTicket := func(id, queueID int, created time.Time) {
+		_, err := db.Exec(`INSERT INTO ticket (id, tn, title, queue_id, ticket_lock_id, user_id, responsible_user_id,
+			ticket_priority_id, ticket_state_id, timeout, until_time, escalation_time, escalation_update_time,
+			escalation_response_time, escalation_solution_time, archive_flag, create_time, create_by, change_time, change_by)
+			VALUES (?, ?, 'Printer', ?, 1, 1, 1, 3, 1, 0, 0, 0, 0, 0, 0, 0, ?, 1, ?, 1)`,
+			id, id, queueID, created, created)
+		require.NoError(t, err)
+	}
+	addHistory := func(ticketID int, historyType string, queueID, ownerID, stateID int, at time.Time) {
+		_, err := db.Exec(`INSERT INTO ticket_history_type (name, valid_id, create_time, create_by, change_time, change_by)
+			SELECT ?, 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1
+			WHERE NOT EXISTS (SELECT 1 FROM ticket_history_type WHERE name = ?)`, historyType, historyType)
+		require.NoError(t, err)
+		_, err = db.Exec(`INSERT INTO ticket_history (name, history_type_id, ticket_id, type_id, queue_id, owner_id,
+			priority_id, state_id, create_time, create_by, change_time, change_by)
+			SELECT ?, id, ?, 1, ?, ?, 3, ?, ?, 1, ?, 1 FROM ticket_history_type WHERE name = ?`,
+			historyType, ticketID, queueID, ownerID, stateID, at, at, historyType)
+		require.NoError(t, err)
+	}
+	addTime := func(ticketID, agentID int, minutes float64, at time.Time) {
+		_, err := db.Exec(`INSERT INTO time_accounting (ticket_id, time_unit, create_time, create_by, change_time, change_by)
+			VALUES (?, ?, ?, ?, ?, ?)`, ticketID, minutes, at, agentID, at, agentID)
+		require.NoError(t, err)
+	}
+
+	// Ticket 1: Alice closes it in time, then the customer reopens it
+	addTicket(1, 2, day(3, 8))
+	addHistory(1, "NewTicket", 2, 2, 1, day(3, 8))
+	addHistory(1, "StateUpdate", 2, 2, 4, day(3, 8).Add(30*time.Minute))
+	addHistory(1, "StateUpdate", 2, 2, 2, day(1, 8))
+	addTime(1, 2, 30, day(3, 8))
+	addTime(1, 2, 15, day(1, 9))
+	// Ticket 2: Bob closes it after its solution time escalated
+	addTicket(2, 2, day(5, 8))
+	addHistory(2, "NewTicket", 2, 3, 1, day(5, 8))
+	addHistory(2, "EscalationSolutionTimeStart", 2, 3, 1, day(5, 9))
+	addHistory(2, "StateUpdate", 2, 3, 4, day(2, 8))
+	addTime(2, 3, 20, day(2, 8))
+	// Ticket 3: Alice closes it in a queue without a target
+	addTicket(3, 1, day(2, 8))
+	addHistory(3, "NewTicket", 1, 2, 1, day(2, 8))
+	addHistory(3, "StateUpdate", 1, 2, 4, day(1, 8))
+	addTime(3, 2, 15, day(1, 8))
+	// Ticket 4: closed today, after the window
+	addTicket(4, 1, day(0, 7))
+	addHistory(4, "NewTicket", 1, 2, 4, day(0, 7))
+
+	svc := NewAgentStatsService(db)
+	svc.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	ok, err := svc.CanView(ctx, 4)
+	require.NoError(t, err)
+	assert.True(t, ok, "stats group members may view")
+	ok, err = svc.CanView(ctx, 2)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	report, err := svc.Stats(ctx, "", models.AgentStatsByAgent)
+	require.NoError(t, err)
+	assert.Equal(t, "30d", report.Window)
+	assert.Equal(t, "2026-09-16", report.From)
+	assert.Equal(t, "2026-10-15", report.To)
+	require.Len(t, report.Agents, 2)
+
+	alice, bob := report.Agents[0], report.Agents[1]
+	assert.Equal(t, "alice", alice.AgentLogin)
+	assert.Equal(t, "Alice Agent", alice.AgentName)
+	assert.Equal(t, 2, alice.Resolved)
+	assert.Equal(t, 1, alice.Reopened)
+	assert.Equal(t, 0.5, *alice.ReopenedRatio)
+	assert.Equal(t, 2, alice.HandledTickets)
+	assert.Equal(t, 30.0, *alice.AvgHandleTimeMinutes)
+	assert.Equal(t, 1, alice.SLATickets)
+	assert.Equal(t, 100.0, *alice.SLACompliance)
+	assert.Nil(t, alice.QueueID)
+
+	assert.Equal(t, "bob", bob.AgentLogin)
+	assert.Equal(t, 1, bob.Resolved)
+	assert.Zero(t, *bob.ReopenedRatio)
+	assert.Equal(t, 0.0, *bob.SLACompliance)
+
+	report, err = svc.Stats(ctx, "2d", models.AgentStatsByQueue)
+	require.NoError(t, err)
+	require.Len(t, report.Agents, 3)
+	assert.Equal(t, "alice", report.Agents[0].AgentLogin)
+	assert.Equal(t, 1, *report.Agents[0].QueueID)
+	assert.Equal(t, 1, report.Agents[0].Resolved)
+	assert.Equal(t, 2, *report.Agents[1].QueueID)
+	assert.Zero(t, report.Agents[1].Resolved, "ticket 1 was closed before the window")
+	assert.Equal(t, 1, report.Agents[1].HandledTickets, "time accounted on ticket 1")
+	assert.Nil(t, report.Agents[1].SLACompliance)
+	assert.Equal(t, "bob", report.Agents[2].AgentLogin)
+	assert.Equal(t, "Raw", report.Agents[2].QueueName)
+
+	report, err = svc.Stats(ctx, "30d", models.AgentStatsByTeam)
+	require.NoError(t, err)
+	require.NotEmpty(t, report.Agents)
+	assert.NotNil(t, report.Agents[0].TeamID)
+	assert.NotEmpty(t, report.Agents[0].TeamName)
+
+	csvData, err := RenderAgentStatsCSV(report)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(csvData)), "\n")
+	assert.Equal(t, "Agent ID,Login,Name,Team ID,Team,Resolved,Reopened,Reopened Ratio,Handled Tickets,"+
+		"Avg Handle Time (min),SLA Tickets,SLA Met,SLA Compliance (%)", lines[0])
+	assert.Len(t, lines, len(report.Agents)+1)
+
+	_, err = svc.Stats(ctx, "30d", "owner")
+	assert.ErrorIs(t, err, ErrAgentStatsGrouping)
+	_, err = svc.Stats(ctx, "0d", "")
+	assert.ErrorIs(t, err, ErrStatsWindow)
+}
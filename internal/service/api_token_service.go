@@ -5,6 +5,7 @@ import (
 	"crypto/rand"
 	"database/sql"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"log"
 	"strings"
@@ -16,6 +17,14 @@ import (
 	"github.com/goatkit/goatflow/internal/repository"
 )
 
+// Errors returned by APITokenService.
+var (
+	ErrAPITokenNotFound = errors.New("token not found")
+	ErrAPITokenRevoked  = errors.New("token has been revoked")
+	ErrAPITokenExpired  = errors.New("token has expired")
+	ErrAPITokenUser     = errors.New("token user not found")
+)
+
 // APITokenService handles API token operations
 type APITokenService struct {
 	repo *repository.APITokenRepository
@@ -35,6 +44,37 @@ func (s *APITokenService) GenerateToken(ctx context.Context, req *models.APIToke
 		return nil, err
 	}
 
+	// Validate and normalize the IP allow-list
+	allowedIPs, err := models.NormalizeAllowedIPs(req.AllowedIPs)
+	if err != nil {
+		return nil, err
+	}
+
+	// Calculate expiration
+	var expiresAt sql.NullTime
+	if req.ExpiresIn != "" && req.ExpiresIn != "never" {
+		duration, err := parseExpiration(req.ExpiresIn)
+		if err != nil {
+			return nil, fmt.Errorf("invalid expiration: %w", err)
+		}
+		expiresAt = sql.NullTime{Time: time.Now().Add(duration), Valid: true}
+	}
+
+	return s.issueToken(ctx, &models.APIToken{
+		UserID:     userID,
+		UserType:   userType,
+		Name:       req.Name,
+		Scopes:     req.Scopes,
+		AllowedIPs: allowedIPs,
+		ExpiresAt:  expiresAt,
+		RateLimit:  models.DefaultRateLimit,
+		CreatedBy:  sql.NullInt64{Int64: int64(createdBy), Valid: createdBy > 0},
+	})
+}
+
+// issueToken generates the secret for token, stores it and returns the
+// full token once.
+func (s *APITokenService) issueToken(ctx context.Context, token *models.APIToken) (*models.APITokenCreateResponse, error) {
 	// Generate random token
 	randomBytes := make([]byte, models.TokenRandomLength)
 	if _, err := rand.Read(randomBytes); err != nil {
@@ -54,29 +94,9 @@ func (s *APITokenService) GenerateToken(ctx context.Context, req *models.APIToke
 		return nil, fmt.Errorf("hash token: %w", err)
 	}
 
-	// Calculate expiration
-	var expiresAt sql.NullTime
-	if req.ExpiresIn != "" && req.ExpiresIn != "never" {
-		duration, err := parseExpiration(req.ExpiresIn)
-		if err != nil {
-			return nil, fmt.Errorf("invalid expiration: %w", err)
-		}
-		expiresAt = sql.NullTime{Time: time.Now().Add(duration), Valid: true}
-	}
-
-	// Create token record
-	token := &models.APIToken{
-		UserID:    userID,
-		UserType:  userType,
-		Name:      req.Name,
-		Prefix:    prefix,
-		TokenHash: string(hash),
-		Scopes:    req.Scopes,
-		ExpiresAt: expiresAt,
-		RateLimit: models.DefaultRateLimit,
-		CreatedAt: time.Now(),
-		CreatedBy: sql.NullInt64{Int64: int64(createdBy), Valid: createdBy > 0},
-	}
+	token.Prefix = prefix
+	token.TokenHash = string(hash)
+	token.CreatedAt = time.Now()
 
 	// Insert into database
 	id, err := s.repo.Create(ctx, token)
@@ -86,17 +106,18 @@ func (s *APITokenService) GenerateToken(ctx context.Context, req *models.APIToke
 
 	// Build response
 	resp := &models.APITokenCreateResponse{
-		ID:        id,
-		Name:      req.Name,
-		Prefix:    prefix,
-		Token:     fullToken,
-		Scopes:    req.Scopes,
-		CreatedAt: token.CreatedAt,
-		Warning:   "Save this token now. It won't be shown again.",
+		ID:         id,
+		Name:       token.Name,
+		Prefix:     prefix,
+		Token:      fullToken,
+		Scopes:     token.Scopes,
+		AllowedIPs: token.AllowedIPs,
+		CreatedAt:  token.CreatedAt,
+		Warning:    "Save this token now. It won't be shown again.",
 	}
 
-	if expiresAt.Valid {
-		exp := expiresAt.Time.Format(time.RFC3339)
+	if token.ExpiresAt.Valid {
+		exp := token.ExpiresAt.Time.Format(time.RFC3339)
 		resp.ExpiresAt = &exp
 	}
 
@@ -169,12 +190,13 @@ func (s *APITokenService) ListUserTokens(ctx context.Context, userID int, userTy
 	items := make([]*models.APITokenListItem, 0, len(tokens))
 	for _, t := range tokens {
 		item := &models.APITokenListItem{
-			ID:        t.ID,
-			Name:      t.Name,
-			Prefix:    t.Prefix,
-			Scopes:    t.Scopes,
-			CreatedAt: t.CreatedAt.Format(time.RFC3339),
-			IsActive:  t.IsActive(),
+			ID:         t.ID,
+			Name:       t.Name,
+			Prefix:     t.Prefix,
+			Scopes:     t.Scopes,
+			AllowedIPs: t.AllowedIPs,
+			CreatedAt:  t.CreatedAt.Format(time.RFC3339),
+			IsActive:   t.IsActive(),
 		}
 		if t.ExpiresAt.Valid {
 			exp := t.ExpiresAt.Time.Format(time.RFC3339)
@@ -198,6 +220,25 @@ func (s *APITokenService) GenerateTokenForUser(ctx context.Context, req *models.
 	return s.GenerateToken(ctx, req, targetUserID, userType, adminID)
 }
 
+// UserIDByLogin returns the ID of the agent or customer user a token is
+// issued to, by login.
+func (s *APITokenService) UserIDByLogin(ctx context.Context, login string, userType models.APITokenUserType) (int, error) {
+	id, err := s.repo.UserIDByLogin(ctx, login, userType)
+	if err != nil {
+		return 0, err
+	}
+	if id == 0 {
+		return 0, fmt.Errorf("%w: %s %s", ErrAPITokenUser, userType, login)
+	}
+	return id, nil
+}
+
+// OwnerLogin returns the login of a token's owner, or "" if the user no
+// longer exists.
+func (s *APITokenService) OwnerLogin(ctx context.Context, token *models.APIToken) (string, error) {
+	return s.repo.UserLogin(ctx, token.UserID, token.UserType)
+}
+
 // GetToken returns a token by ID (for admin verification)
 func (s *APITokenService) GetToken(ctx context.Context, tokenID int64) (*models.APIToken, error) {
 	return s.repo.GetByID(ctx, tokenID)
@@ -211,12 +252,12 @@ func (s *APITokenService) RevokeToken(ctx context.Context, tokenID int64, userID
 		return fmt.Errorf("get token: %w", err)
 	}
 	if token == nil {
-		return fmt.Errorf("token not found")
+		return ErrAPITokenNotFound
 	}
 
 	// Verify ownership (unless admin)
 	if token.UserID != userID || token.UserType != userType {
-		return fmt.Errorf("token not found") // Don't reveal existence
+		return ErrAPITokenNotFound // Don't reveal existence
 	}
 
 	return s.repo.Revoke(ctx, tokenID, revokedBy)
@@ -227,6 +268,47 @@ func (s *APITokenService) RevokeTokenAdmin(ctx context.Context, tokenID int64, r
 	return s.repo.Revoke(ctx, tokenID, revokedBy)
 }
 
+// RotateToken replaces one of the user's active tokens with a new secret.
+// The new token keeps the name, scopes, IP allow-list and expiry of the old
+// one, which is revoked, so rotating never extends a token's life.
+func (s *APITokenService) RotateToken(ctx context.Context, tokenID int64, userID int, userType models.APITokenUserType) (*models.APITokenCreateResponse, error) {
+	old, err := s.repo.GetByID(ctx, tokenID)
+	if err != nil {
+		return nil, fmt.Errorf("get token: %w", err)
+	}
+	if old == nil || old.UserID != userID || old.UserType != userType {
+		return nil, ErrAPITokenNotFound
+	}
+	if old.IsRevoked() {
+		return nil, ErrAPITokenRevoked
+	}
+	if old.IsExpired() {
+		return nil, ErrAPITokenExpired
+	}
+
+	resp, err := s.issueToken(ctx, &models.APIToken{
+		UserID:     old.UserID,
+		UserType:   old.UserType,
+		Name:       old.Name,
+		Scopes:     old.Scopes,
+		AllowedIPs: old.AllowedIPs,
+		ExpiresAt:  old.ExpiresAt,
+		RateLimit:  old.RateLimit,
+		CreatedBy:  sql.NullInt64{Int64: int64(userID), Valid: userID > 0},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.Revoke(ctx, tokenID, userID); err != nil {
+		// Don't leave two live tokens behind
+		_ = s.repo.Revoke(ctx, resp.ID, userID) //nolint:errcheck // Best effort cleanup
+		return nil, fmt.Errorf("revoke old token: %w", err)
+	}
+
+	return resp, nil
+}
+
 // UpdateLastUsed updates the last used timestamp
 func (s *APITokenService) UpdateLastUsed(ctx context.Context, tokenID int64, ip string) error {
 	return s.repo.UpdateLastUsed(ctx, tokenID, ip)
@@ -240,7 +322,7 @@ func (s *APITokenService) ListAllTokens(ctx context.Context, includeRevoked bool
 // validateScopes validates that scopes are valid and appropriate for user type
 func (s *APITokenService) validateScopes(scopes []string, userType models.APITokenUserType) error {
 	for _, scope := range scopes {
-		if _, ok := models.ValidScopes[scope]; !ok {
+		if !models.IsGrantableScope(scope) {
 			return fmt.Errorf("invalid scope: %s", scope)
 		}
 
@@ -1,9 +1,15 @@
 package service
 
 import (
+	"context"
 	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/testutil"
 )
 
 func TestParseExpiration(t *testing.T) {
@@ -138,3 +144,93 @@ func TestValidateScopes(t *testing.T) {
 		})
 	}
 }
+
+func TestRotateToken(t *testing.T) {
+	db := testutil.UseMigratedDB(t)
+	svc := NewAPITokenService(db)
+	ctx := context.Background()
+
+	created, err := svc.GenerateToken(ctx, &models.APITokenCreateRequest{
+		Name:       "CI",
+		Scopes:     []string{"tickets:read"},
+		ExpiresIn:  "30d",
+		AllowedIPs: []string{"10.0.0.0/8"},
+	}, 7, models.APITokenUserAgent, 7)
+	require.NoError(t, err)
+
+	t.Run("other users cannot rotate", func(t *testing.T) {
+		_, err := svc.RotateToken(ctx, created.ID, 8, models.APITokenUserAgent)
+		assert.ErrorIs(t, err, ErrAPITokenNotFound)
+		_, err = svc.RotateToken(ctx, created.ID, 7, models.APITokenUserCustomer)
+		assert.ErrorIs(t, err, ErrAPITokenNotFound)
+	})
+
+	// Rotating later must not push the expiry out
+	_, err = db.Exec("UPDATE user_api_tokens SET created_at = ? WHERE id = ?", time.Now().AddDate(0, 0, -20), created.ID)
+	require.NoError(t, err)
+
+	rotated, err := svc.RotateToken(ctx, created.ID, 7, models.APITokenUserAgent)
+	require.NoError(t, err)
+	assert.NotEqual(t, created.ID, rotated.ID)
+	assert.NotEqual(t, created.Token, rotated.Token)
+	assert.Equal(t, "CI", rotated.Name)
+	assert.Equal(t, []string{"tickets:read"}, rotated.Scopes)
+	assert.Equal(t, []string{"10.0.0.0/8"}, rotated.AllowedIPs)
+	require.NotNil(t, rotated.ExpiresAt)
+	assert.Equal(t, *created.ExpiresAt, *rotated.ExpiresAt, "the rotated token keeps the original expiry")
+
+	old, err := svc.GetToken(ctx, created.ID)
+	require.NoError(t, err)
+	assert.True(t, old.IsRevoked())
+
+	_, err = svc.VerifyToken(ctx, created.Token)
+	assert.Error(t, err)
+	_, err = svc.VerifyToken(ctx, rotated.Token)
+	assert.NoError(t, err)
+
+	_, err = svc.RotateToken(ctx, created.ID, 7, models.APITokenUserAgent)
+	assert.ErrorIs(t, err, ErrAPITokenRevoked)
+
+	t.Run("expired tokens cannot be rotated", func(t *testing.T) {
+		_, err := db.Exec("UPDATE user_api_tokens SET expires_at = ? WHERE id = ?", time.Now().Add(-time.Hour), rotated.ID)
+		require.NoError(t, err)
+		_, err = svc.RotateToken(ctx, rotated.ID, 7, models.APITokenUserAgent)
+		assert.ErrorIs(t, err, ErrAPITokenExpired)
+
+		current, err := svc.GetToken(ctx, rotated.ID)
+		require.NoError(t, err)
+		assert.False(t, current.IsRevoked(), "a refused rotation leaves the token alone")
+	})
+
+	t.Run("tokens without expiry stay without", func(t *testing.T) {
+		forever, err := svc.GenerateToken(ctx, &models.APITokenCreateRequest{Name: "Forever", Scopes: []string{"tickets:read"}},
+			7, models.APITokenUserAgent, 7)
+		require.NoError(t, err)
+		next, err := svc.RotateToken(ctx, forever.ID, 7, models.APITokenUserAgent)
+		require.NoError(t, err)
+		assert.Nil(t, next.ExpiresAt)
+	})
+}
+
+func TestAPITokenUsersByLogin(t *testing.T) {
+	db := testutil.UseMigratedDB(t)
+	_, err := db.Exec(`INSERT INTO users (login, pw, first_name, last_name, valid_id, create_time, create_by, change_time, change_by)
+		VALUES ('ci@example.com', 'x', 'CI', 'Bot', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`)
+	require.NoError(t, err)
+	svc := NewAPITokenService(db)
+	ctx := context.Background()
+
+	id, err := svc.UserIDByLogin(ctx, "ci@example.com", models.APITokenUserAgent)
+	require.NoError(t, err)
+	_, err = svc.UserIDByLogin(ctx, "ci@example.com", models.APITokenUserCustomer)
+	assert.ErrorIs(t, err, ErrAPITokenUser)
+
+	created, err := svc.GenerateToken(ctx, &models.APITokenCreateRequest{Name: "gk", Scopes: []string{"tickets:read"}, ExpiresIn: "90d"},
+		id, models.APITokenUserAgent, 1)
+	require.NoError(t, err)
+	token, err := svc.VerifyToken(ctx, created.Token)
+	require.NoError(t, err)
+	login, err := svc.OwnerLogin(ctx, token)
+	require.NoError(t, err)
+	assert.Equal(t, "ci@example.com", login)
+}
@@ -0,0 +1,385 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/goatkit/goatflow/internal/events"
+	"github.com/goatkit/goatflow/internal/history"
+	"github.com/goatkit/goatflow/internal/mailqueue"
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/repository"
+)
+
+// Errors returned by ApprovalService.
+var (
+	ErrApprovalRuleNotFound     = errors.New("approval rule not found")
+	ErrApprovalRuleNameRequired = errors.New("approval rule name is required")
+	ErrApprovalRuleNameExists   = errors.New("an approval rule with this name already exists")
+	ErrApprovalInvalidAction    = errors.New("action must be one of close, move")
+	ErrApprovalInvalidGroup     = errors.New("approver group must be a valid group")
+	ErrApprovalNotFound         = errors.New("approval request not found")
+	ErrApprovalTicketNotFound   = errors.New("ticket not found")
+	ErrApprovalPending          = errors.New("an approval request for this action is already pending")
+	ErrApprovalNotPending       = errors.New("approval request is no longer pending")
+	ErrApprovalNotApprover      = errors.New("only members of the approver group can decide this request")
+	ErrApprovalNotRequester     = errors.New("only the requesting agent can cancel this request")
+)
+
+// ApprovalService guards ticket actions with approval rules. An agent who is
+// not in a rule's approver group raises a pending request instead of acting;
+// approving the request applies the change.
+type ApprovalService struct {
+	repo *repository.ApprovalRepository
+	// recordHistory adds a ticket history entry; tests replace it.
+	recordHistory func(ctx context.Context, ticketID int, historyType, message string, userID int)
+	mail          *mailqueue.MailQueueRepository
+	from          string
+	now           func() time.Time
+}
+
+// NewApprovalService creates an approval service. Email notifications are
+// off until WithEmail is called.
+func NewApprovalService(db *sql.DB) *ApprovalService {
+	recorder := history.NewRecorder(repository.NewTicketRepository(db))
+	return &ApprovalService{
+		repo: repository.NewApprovalRepository(db),
+		recordHistory: func(ctx context.Context, ticketID int, historyType, message string, userID int) {
+			if err := recorder.RecordByTicketID(ctx, nil, ticketID, nil, historyType, message, userID); err != nil {
+				log.Printf("approval: history for ticket %d failed: %v", ticketID, err)
+			}
+		},
+		now: time.Now,
+	}
+}
+
+// WithEmail makes the service queue notification emails from the address.
+func (s *ApprovalService) WithEmail(queue *mailqueue.MailQueueRepository, from string) *ApprovalService {
+	s.mail = queue
+	s.from = from
+	return s
+}
+
+// ListRules returns all valid approval rules.
+func (s *ApprovalService) ListRules(ctx context.Context) ([]models.ApprovalRule, error) {
+	return s.repo.ListRules(ctx)
+}
+
+// GetRule returns an approval rule.
+func (s *ApprovalService) GetRule(ctx context.Context, id int) (*models.ApprovalRule, error) {
+	rule, err := s.repo.GetRule(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if rule == nil {
+		return nil, ErrApprovalRuleNotFound
+	}
+	return rule, nil
+}
+
+// CreateRule validates and stores a new approval rule, setting its ID.
+func (s *ApprovalService) CreateRule(ctx context.Context, rule *models.ApprovalRule) error {
+	if err := s.validateRule(ctx, rule); err != nil {
+		return err
+	}
+	id, err := s.repo.CreateRule(ctx, rule)
+	if err != nil {
+		return err
+	}
+	rule.ID = id
+	return nil
+}
+
+// UpdateRule validates and stores changes to an approval rule.
+func (s *ApprovalService) UpdateRule(ctx context.Context, rule *models.ApprovalRule, userID int) error {
+	if err := s.validateRule(ctx, rule); err != nil {
+		return err
+	}
+	if err := s.repo.UpdateRule(ctx, rule, userID); errors.Is(err, sql.ErrNoRows) {
+		return ErrApprovalRuleNotFound
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+// DeleteRule invalidates an approval rule. Requests already raised under it
+// stay pending and can still be decided.
+func (s *ApprovalService) DeleteRule(ctx context.Context, id int, userID int) error {
+	if err := s.repo.InvalidateRule(ctx, id, userID); errors.Is(err, sql.ErrNoRows) {
+		return ErrApprovalRuleNotFound
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *ApprovalService) validateRule(ctx context.Context, rule *models.ApprovalRule) error {
+	rule.Name = strings.TrimSpace(rule.Name)
+	if rule.Name == "" {
+		return ErrApprovalRuleNameRequired
+	}
+	if !rule.Action.IsValid() {
+		return ErrApprovalInvalidAction
+	}
+	if rule.QueueID != nil && *rule.QueueID <= 0 {
+		rule.QueueID = nil
+	}
+	exists, err := s.repo.GroupExists(ctx, rule.ApproverGroupID)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrApprovalInvalidGroup
+	}
+	taken, err := s.repo.RuleNameExists(ctx, rule.Name, rule.ID)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return ErrApprovalRuleNameExists
+	}
+	return nil
+}
+
+// RuleFor returns the rule that stops the agent from performing action on
+// tickets in queueID without approval, or nil when the agent may act
+// directly: no rule applies or the agent is one of its approvers.
+func (s *ApprovalService) RuleFor(ctx context.Context, queueID int, action models.ApprovalAction, userID int) (*models.ApprovalRule, error) {
+	rule, err := s.repo.MatchRule(ctx, queueID, action)
+	if err != nil || rule == nil {
+		return nil, err
+	}
+	member, err := s.repo.IsGroupMember(ctx, rule.ApproverGroupID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if member {
+		return nil, nil
+	}
+	return rule, nil
+}
+
+// RequestClose raises an approval request when setting stateID on the
+// ticket needs approval. It returns nil when the agent may go ahead; states
+// that do not close the ticket never need approval.
+func (s *ApprovalService) RequestClose(ctx context.Context, ticketID, stateID, userID int, reason string) (*models.ApprovalRequest, error) {
+	closes, err := s.repo.StateClosesTicket(ctx, stateID)
+	if err != nil || !closes {
+		return nil, err
+	}
+	return s.request(ctx, ticketID, models.ApprovalActionClose, stateID, userID, reason)
+}
+
+// RequestMove raises an approval request when moving the ticket to queueID
+// needs approval. Move rules protect the queue the ticket is moved out of.
+func (s *ApprovalService) RequestMove(ctx context.Context, ticketID, queueID, userID int, reason string) (*models.ApprovalRequest, error) {
+	return s.request(ctx, ticketID, models.ApprovalActionMove, queueID, userID, reason)
+}
+
+func (s *ApprovalService) request(ctx context.Context, ticketID int, action models.ApprovalAction, targetID, userID int, reason string) (*models.ApprovalRequest, error) {
+	_, queueID, ok, err := s.repo.TicketQueue(ctx, ticketID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrApprovalTicketNotFound
+	}
+	if action == models.ApprovalActionMove && queueID == targetID {
+		return nil, nil
+	}
+	rule, err := s.RuleFor(ctx, queueID, action, userID)
+	if err != nil || rule == nil {
+		return nil, err
+	}
+
+	pending, err := s.repo.PendingRequest(ctx, ticketID, action)
+	if err != nil {
+		return nil, err
+	}
+	if pending != nil {
+		return pending, ErrApprovalPending
+	}
+
+	id, err := s.repo.CreateRequest(ctx, &models.ApprovalRequest{
+		RuleID:        rule.ID,
+		TicketID:      ticketID,
+		Action:        action,
+		TargetID:      targetID,
+		Reason:        strings.TrimSpace(reason),
+		RequestedBy:   userID,
+		RequestedTime: s.now(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	req, err := s.repo.GetRequest(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if req == nil {
+		return nil, ErrApprovalNotFound
+	}
+
+	s.recordHistory(ctx, ticketID, history.TypeMisc,
+		fmt.Sprintf("Approval requested to %s ticket (rule %q)", action, rule.Name), userID)
+	s.publish(req)
+	if s.mail != nil {
+		recipients, err := s.repo.GroupMemberEmails(ctx, rule.ApproverGroupID)
+		if err != nil {
+			log.Printf("approval: loading approvers for request %d failed: %v", req.ID, err)
+		}
+		s.notify(ctx, recipients,
+			fmt.Sprintf("[Ticket#%s] Approval requested: %s", req.TicketNumber, action),
+			approvalRequestedBody(req))
+	}
+	return req, nil
+}
+
+// ListRequests returns the approval requests matching the filter.
+func (s *ApprovalService) ListRequests(ctx context.Context, filter models.ApprovalRequestFilter) ([]models.ApprovalRequest, error) {
+	return s.repo.ListRequests(ctx, filter)
+}
+
+// GetRequest returns an approval request.
+func (s *ApprovalService) GetRequest(ctx context.Context, id int) (*models.ApprovalRequest, error) {
+	req, err := s.repo.GetRequest(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if req == nil {
+		return nil, ErrApprovalNotFound
+	}
+	return req, nil
+}
+
+// CanDecide reports whether the agent is in the approver group of the
+// request's rule.
+func (s *ApprovalService) CanDecide(ctx context.Context, req *models.ApprovalRequest, userID int) (bool, error) {
+	return s.repo.IsGroupMember(ctx, req.ApproverGroupID, userID)
+}
+
+// Approve approves a pending request and applies its change to the ticket.
+func (s *ApprovalService) Approve(ctx context.Context, id, userID int, comment string) (*models.ApprovalRequest, error) {
+	return s.decide(ctx, id, userID, models.ApprovalStatusApproved, comment)
+}
+
+// Reject rejects a pending request; the ticket is left unchanged.
+func (s *ApprovalService) Reject(ctx context.Context, id, userID int, comment string) (*models.ApprovalRequest, error) {
+	return s.decide(ctx, id, userID, models.ApprovalStatusRejected, comment)
+}
+
+// Cancel withdraws a pending request. Only the requesting agent may cancel.
+func (s *ApprovalService) Cancel(ctx context.Context, id, userID int) (*models.ApprovalRequest, error) {
+	return s.decide(ctx, id, userID, models.ApprovalStatusCancelled, "")
+}
+
+func (s *ApprovalService) decide(ctx context.Context, id, userID int, status models.ApprovalStatus, comment string) (*models.ApprovalRequest, error) {
+	req, err := s.GetRequest(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if req.Status != models.ApprovalStatusPending {
+		return req, ErrApprovalNotPending
+	}
+	if status == models.ApprovalStatusCancelled {
+		if req.RequestedBy != userID {
+			return nil, ErrApprovalNotRequester
+		}
+	} else if ok, err := s.CanDecide(ctx, req, userID); err != nil {
+		return nil, err
+	} else if !ok {
+		return nil, ErrApprovalNotApprover
+	}
+
+	comment = strings.TrimSpace(comment)
+	if err := s.repo.Decide(ctx, req, status, userID, comment, s.now()); errors.Is(err, repository.ErrApprovalAlreadyDecided) {
+		return nil, ErrApprovalNotPending
+	} else if err != nil {
+		return nil, err
+	}
+	if req, err = s.GetRequest(ctx, id); err != nil {
+		return nil, err
+	}
+
+	historyType, message := history.TypeMisc, fmt.Sprintf("Approval to %s ticket %s", req.Action, status)
+	if status == models.ApprovalStatusApproved {
+		historyType = history.TypeStateUpdate
+		if req.Action == models.ApprovalActionMove {
+			historyType = history.TypeQueueMove
+		}
+		message = fmt.Sprintf("Approval to %s ticket approved; change applied", req.Action)
+	}
+	if comment != "" {
+		message += ": " + comment
+	}
+	s.recordHistory(ctx, req.TicketID, historyType, message, userID)
+	s.publish(req)
+	if s.mail != nil && status != models.ApprovalStatusCancelled {
+		recipient, err := s.repo.UserEmail(ctx, req.RequestedBy)
+		if err != nil {
+			log.Printf("approval: loading requester of request %d failed: %v", req.ID, err)
+		}
+		if recipient != "" {
+			s.notify(ctx, []string{recipient},
+				fmt.Sprintf("[Ticket#%s] Approval %s: %s", req.TicketNumber, status, req.Action),
+				approvalDecidedBody(req))
+		}
+	}
+	return req, nil
+}
+
+func (s *ApprovalService) publish(req *models.ApprovalRequest) {
+	events.Publish(events.Event{
+		Type:     events.TypeTicketApproval,
+		QueueID:  req.QueueID,
+		TicketID: req.TicketID,
+		Data:     req,
+	})
+}
+
+// notify queues an email to each recipient. Failures are logged so that a
+// mail problem never undoes the approval step itself.
+func (s *ApprovalService) notify(ctx context.Context, recipients []string, subject, body string) {
+	for _, to := range recipients {
+		sender := s.from
+		item := &mailqueue.MailQueueItem{
+			Sender:     &sender,
+			Recipient:  to,
+			RawMessage: mailqueue.BuildEmailMessage(s.from, to, subject, body),
+			CreateTime: s.now(),
+		}
+		if err := s.mail.Insert(ctx, item); err != nil {
+			log.Printf("approval: queueing notification for %s failed: %v", to, err)
+		}
+	}
+}
+
+func approvalRequestedBody(req *models.ApprovalRequest) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Agent %d asks for approval to %s ticket %s (rule %q).\n",
+		req.RequestedBy, req.Action, req.TicketNumber, req.RuleName)
+	if req.Reason != "" {
+		fmt.Fprintf(&b, "\nReason: %s\n", req.Reason)
+	}
+	fmt.Fprintf(&b, "\nApprove or reject approval request %d in GoatFlow.\n", req.ID)
+	return b.String()
+}
+
+func approvalDecidedBody(req *models.ApprovalRequest) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Your request to %s ticket %s was %s", req.Action, req.TicketNumber, req.Status)
+	if req.DecidedBy != nil {
+		fmt.Fprintf(&b, " by agent %d", *req.DecidedBy)
+	}
+	b.WriteString(".\n")
+	if req.DecisionComment != "" {
+		fmt.Fprintf(&b, "\nComment: %s\n", req.DecisionComment)
+	}
+	return b.String()
+}
@@ -0,0 +1,187 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/history"
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/testutil"
+)
+
+type approvalHistoryEntry struct {
+	ticketID    int
+	historyType string
+	message     string
+	userID      int
+}
+
+func newApprovalTestService(t *testing.T) (*ApprovalService, *sql.DB, *[]approvalHistoryEntry) {
+	t.Helper()
+	db := testutil.MigratedDB(t)
+	for _, stmt := range []string{
+		`INSERT INTO users (id, login, pw, first_name, last_name, valid_id, create_time, create_by, change_time, change_by)
+			VALUES (1, 'root@localhost', 'x', 'Admin', 'OTRS', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1),
+			       (5, 'agent@example.com', 'x', 'Agent', 'Five', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1),
+			       (6, 'lead@example.com', 'x', 'Lead', 'Six', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1),
+			       (7, 'other', 'x', 'Other', 'Seven', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+		`INSERT INTO groups (id, name, valid_id, create_time, create_by, change_time, change_by)
+			VALUES (10, 'leads', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1),
+			       (11, 'retired', 2, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+		`INSERT INTO group_user (user_id, group_id, permission_key, create_time, create_by, change_time, change_by)
+			VALUES (6, 10, 'rw', CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+		// Ticket 1 is open in queue 3, ticket 2 open in queue 1.
+		`INSERT INTO ticket (id, tn, title, queue_id, ticket_lock_id, user_id, responsible_user_id,
+			ticket_priority_id, ticket_state_id, timeout, until_time, escalation_time, escalation_update_time,
+			escalation_response_time, escalation_solution_time, archive_flag, create_time, create_by, change_time, change_by)
+			VALUES (1, '1001', 'Printer', 3, 1, 1, 1, 3, 2, 0, 0, 0, 0, 0, 0, 0, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1),
+			       (2, '1002', 'Printer', 1, 1, 1, 1, 3, 2, 0, 0, 0, 0, 0, 0, 0, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+	} {
+		_, err := db.Exec(stmt)
+		require.NoError(t, err, stmt)
+	}
+
+	var entries []approvalHistoryEntry
+	svc := NewApprovalService(db)
+	svc.recordHistory = func(_ context.Context, ticketID int, historyType, message string, userID int) {
+		entries = append(entries, approvalHistoryEntry{ticketID, historyType, message, userID})
+	}
+	svc.now = func() time.Time { return time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC) }
+	return svc, db, &entries
+}
+
+func createApprovalTestRule(t *testing.T, svc *ApprovalService, queueID *int, action models.ApprovalAction) *models.ApprovalRule {
+	t.Helper()
+	rule := &models.ApprovalRule{Name: "Protected " + string(action), QueueID: queueID, Action: action, ApproverGroupID: 10, CreateBy: 1}
+	require.NoError(t, svc.CreateRule(context.Background(), rule))
+	return rule
+}
+
+func TestApprovalService_CloseNeedsApproval(t *testing.T) {
+	svc, db, entries := newApprovalTestService(t)
+	ctx := context.Background()
+	createApprovalTestRule(t, svc, intRef(3), models.ApprovalActionClose)
+
+	// Other queues and non-closing states go ahead.
+	req, err := svc.RequestClose(ctx, 2, 4, 5, "")
+	require.NoError(t, err)
+	assert.Nil(t, req)
+	req, err = svc.RequestClose(ctx, 1, 2, 5, "")
+	require.NoError(t, err)
+	assert.Nil(t, req)
+
+	// Approvers close directly.
+	req, err = svc.RequestClose(ctx, 1, 4, 6, "")
+	require.NoError(t, err)
+	assert.Nil(t, req)
+
+	req, err = svc.RequestClose(ctx, 1, 4, 5, "customer confirmed")
+	require.NoError(t, err)
+	require.NotNil(t, req)
+	assert.Equal(t, models.ApprovalStatusPending, req.Status)
+	assert.Equal(t, "1001", req.TicketNumber)
+	assert.Equal(t, 3, req.QueueID)
+	assert.Equal(t, "customer confirmed", req.Reason)
+	require.Len(t, *entries, 1)
+	assert.Equal(t, approvalHistoryEntry{1, history.TypeMisc, `Approval requested to close ticket (rule "Protected close")`, 5}, (*entries)[0])
+
+	dup, err := svc.RequestClose(ctx, 1, 4, 7, "")
+	assert.ErrorIs(t, err, ErrApprovalPending)
+	assert.Equal(t, req.ID, dup.ID)
+
+	_, err = svc.Approve(ctx, req.ID, 5, "")
+	assert.ErrorIs(t, err, ErrApprovalNotApprover)
+
+	approved, err := svc.Approve(ctx, req.ID, 6, "ok")
+	require.NoError(t, err)
+	assert.Equal(t, models.ApprovalStatusApproved, approved.Status)
+	assert.Equal(t, 6, *approved.DecidedBy)
+	assert.Equal(t, "ok", approved.DecisionComment)
+
+	var stateID int
+	require.NoError(t, db.QueryRow(`SELECT ticket_state_id FROM ticket WHERE id = 1`).Scan(&stateID))
+	assert.Equal(t, 4, stateID)
+	assert.Equal(t, approvalHistoryEntry{1, history.TypeStateUpdate, "Approval to close ticket approved; change applied: ok", 6}, (*entries)[1])
+
+	_, err = svc.Reject(ctx, req.ID, 6, "")
+	assert.ErrorIs(t, err, ErrApprovalNotPending)
+}
+
+func TestApprovalService_MoveRejectAndCancel(t *testing.T) {
+	svc, db, entries := newApprovalTestService(t)
+	ctx := context.Background()
+	createApprovalTestRule(t, svc, nil, models.ApprovalActionMove)
+
+	req, err := svc.RequestMove(ctx, 2, 1, 5, "")
+	require.NoError(t, err)
+	assert.Nil(t, req, "moving into the same queue is not a move")
+
+	req, err = svc.RequestMove(ctx, 2, 4, 5, "")
+	require.NoError(t, err)
+	require.NotNil(t, req)
+
+	rejected, err := svc.Reject(ctx, req.ID, 6, "keep it here")
+	require.NoError(t, err)
+	assert.Equal(t, models.ApprovalStatusRejected, rejected.Status)
+	var queueID int
+	require.NoError(t, db.QueryRow(`SELECT queue_id FROM ticket WHERE id = 2`).Scan(&queueID))
+	assert.Equal(t, 1, queueID)
+	assert.Equal(t, "Approval to move ticket rejected: keep it here", (*entries)[1].message)
+
+	req, err = svc.RequestMove(ctx, 2, 4, 5, "")
+	require.NoError(t, err)
+	_, err = svc.Cancel(ctx, req.ID, 6)
+	assert.ErrorIs(t, err, ErrApprovalNotRequester)
+	cancelled, err := svc.Cancel(ctx, req.ID, 5)
+	require.NoError(t, err)
+	assert.Equal(t, models.ApprovalStatusCancelled, cancelled.Status)
+
+	pending, err := svc.ListRequests(ctx, models.ApprovalRequestFilter{Status: models.ApprovalStatusPending})
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+	visible, err := svc.ListRequests(ctx, models.ApprovalRequestFilter{VisibleTo: 6})
+	require.NoError(t, err)
+	assert.Len(t, visible, 2)
+	visible, err = svc.ListRequests(ctx, models.ApprovalRequestFilter{VisibleTo: 7})
+	require.NoError(t, err)
+	assert.Empty(t, visible)
+}
+
+func TestApprovalService_RuleValidation(t *testing.T) {
+	svc, _, _ := newApprovalTestService(t)
+	ctx := context.Background()
+
+	for _, tc := range []struct {
+		rule models.ApprovalRule
+		err  error
+	}{
+		{models.ApprovalRule{Name: " ", Action: models.ApprovalActionClose, ApproverGroupID: 10}, ErrApprovalRuleNameRequired},
+		{models.ApprovalRule{Name: "x", Action: "delete", ApproverGroupID: 10}, ErrApprovalInvalidAction},
+		{models.ApprovalRule{Name: "x", Action: models.ApprovalActionClose, ApproverGroupID: 11}, ErrApprovalInvalidGroup},
+	} {
+		rule := tc.rule
+		assert.ErrorIs(t, svc.CreateRule(ctx, &rule), tc.err)
+	}
+
+	rule := createApprovalTestRule(t, svc, intRef(3), models.ApprovalActionClose)
+	dup := &models.ApprovalRule{Name: rule.Name, Action: models.ApprovalActionMove, ApproverGroupID: 10, CreateBy: 1}
+	assert.ErrorIs(t, svc.CreateRule(ctx, dup), ErrApprovalRuleNameExists)
+
+	// A rule for the queue wins over one for every queue.
+	createApprovalTestRule(t, svc, nil, models.ApprovalActionMove)
+	specific := &models.ApprovalRule{Name: "Queue 3 moves", QueueID: intRef(3), Action: models.ApprovalActionMove, ApproverGroupID: 10, CreateBy: 1}
+	require.NoError(t, svc.CreateRule(ctx, specific))
+	match, err := svc.RuleFor(ctx, 3, models.ApprovalActionMove, 5)
+	require.NoError(t, err)
+	assert.Equal(t, specific.ID, match.ID)
+
+	require.NoError(t, svc.DeleteRule(ctx, rule.ID, 1))
+	_, err = svc.GetRule(ctx, rule.ID)
+	assert.ErrorIs(t, err, ErrApprovalRuleNotFound)
+	assert.ErrorIs(t, svc.DeleteRule(ctx, rule.ID, 1), ErrApprovalRuleNotFound)
+}
@@ -0,0 +1,213 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/repository"
+	"github.com/goatkit/goatflow/internal/sysconfig"
+)
+
+// Errors returned by ArticleDraftService.
+var (
+	ErrArticleDraftNotFound        = errors.New("draft not found")
+	ErrArticleDraftTicketNotFound  = errors.New("ticket not found")
+	ErrArticleDraftVersionConflict = errors.New("draft was saved by someone else in the meantime")
+	ErrArticleDraftInvalid         = errors.New("invalid draft")
+)
+
+// Limits of a draft's fields, matching what an article can hold.
+const (
+	articleDraftMaxSubject = 3800
+	articleDraftMaxBody    = 1 << 20
+)
+
+// ArticleDraftService stores the replies agents are writing, one draft per
+// agent and ticket, and reports when others posted to the ticket since the
+// author last looked. Drafts unchanged for longer than the configured
+// retention are purged.
+type ArticleDraftService struct {
+	db   *sql.DB
+	repo *repository.ArticleDraftRepository
+	now  func() time.Time
+}
+
+// NewArticleDraftService creates an article draft service.
+func NewArticleDraftService(db *sql.DB) *ArticleDraftService {
+	return &ArticleDraftService{db: db, repo: repository.NewArticleDraftRepository(db), now: time.Now}
+}
+
+// Mine returns an agent's draft for a ticket.
+func (s *ArticleDraftService) Mine(ctx context.Context, ticketID int64, userID int) (*models.ArticleDraft, error) {
+	draft, err := s.repo.GetForUser(ctx, ticketID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if draft == nil {
+		return nil, ErrArticleDraftNotFound
+	}
+	return draft, s.detectConflict(ctx, draft)
+}
+
+// Get returns a draft the agent may see: their own or a shared one.
+func (s *ArticleDraftService) Get(ctx context.Context, id int64, userID int) (*models.ArticleDraft, error) {
+	draft, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if draft == nil || (draft.UserID != userID && !draft.Shared) {
+		return nil, ErrArticleDraftNotFound
+	}
+	return draft, s.detectConflict(ctx, draft)
+}
+
+// List returns the drafts of a ticket the agent may see: their own and the
+// shared drafts of others.
+func (s *ArticleDraftService) List(ctx context.Context, ticketID int64, userID int) ([]*models.ArticleDraft, error) {
+	drafts, err := s.repo.ListForTicket(ctx, ticketID, userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, draft := range drafts {
+		if err := s.detectConflict(ctx, draft); err != nil {
+			return nil, err
+		}
+	}
+	return drafts, nil
+}
+
+// Save autosaves the agent's draft for a ticket, creating it on the first
+// save. A Version other than 0 must match the stored draft, so two tabs or
+// a co-editor of a shared draft cannot silently overwrite each other. A
+// BaseArticleID of 0 keeps the stored one; new drafts start from the
+// ticket's newest article.
+func (s *ArticleDraftService) Save(ctx context.Context, draft *models.ArticleDraft, userID int) error {
+	if err := validateArticleDraft(draft); err != nil {
+		return err
+	}
+	existing, err := s.repo.GetForUser(ctx, draft.TicketID, userID)
+	if err != nil {
+		return err
+	}
+	if existing == nil {
+		ok, err := s.repo.TicketExists(ctx, draft.TicketID)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrArticleDraftTicketNotFound
+		}
+		if draft.BaseArticleID == 0 {
+			if draft.BaseArticleID, err = s.repo.LatestArticleID(ctx, draft.TicketID); err != nil {
+				return err
+			}
+		}
+		now := s.now()
+		draft.ID, draft.UserID, draft.Version = 0, userID, 1
+		draft.CreateTime, draft.ChangeTime, draft.ChangeBy = now, now, userID
+		if err := s.repo.Create(ctx, draft); err != nil {
+			return err
+		}
+		return s.detectConflict(ctx, draft)
+	}
+	draft.ID = existing.ID
+	return s.update(ctx, existing, draft, userID)
+}
+
+// Update saves changes to a draft by ID: the agent's own draft, or a
+// shared draft of another agent. Version must match the stored draft.
+func (s *ArticleDraftService) Update(ctx context.Context, draft *models.ArticleDraft, userID int) error {
+	if err := validateArticleDraft(draft); err != nil {
+		return err
+	}
+	existing, err := s.repo.Get(ctx, draft.ID)
+	if err != nil {
+		return err
+	}
+	if existing == nil || (existing.UserID != userID && !existing.Shared) {
+		return ErrArticleDraftNotFound
+	}
+	if draft.Version == 0 {
+		return fmt.Errorf("%w: version is required", ErrArticleDraftInvalid)
+	}
+	if existing.UserID != userID {
+		// Only the author decides whether their draft is shared.
+		draft.Shared = existing.Shared
+	}
+	return s.update(ctx, existing, draft, userID)
+}
+
+func (s *ArticleDraftService) update(ctx context.Context, existing, draft *models.ArticleDraft, userID int) error {
+	if draft.Version != 0 && draft.Version != existing.Version {
+		return ErrArticleDraftVersionConflict
+	}
+	if draft.BaseArticleID == 0 {
+		draft.BaseArticleID = existing.BaseArticleID
+	}
+	draft.TicketID, draft.UserID, draft.CreateTime = existing.TicketID, existing.UserID, existing.CreateTime
+	draft.Version = existing.Version + 1
+	draft.ChangeTime, draft.ChangeBy = s.now(), userID
+	if err := s.repo.Update(ctx, draft, existing.Version); err == sql.ErrNoRows {
+		return ErrArticleDraftVersionConflict
+	} else if err != nil {
+		return err
+	}
+	return s.detectConflict(ctx, draft)
+}
+
+// Discard removes the agent's draft for a ticket, e.g. once the reply was
+// posted.
+func (s *ArticleDraftService) Discard(ctx context.Context, ticketID int64, userID int) error {
+	deleted, err := s.repo.DeleteForUser(ctx, ticketID, userID)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrArticleDraftNotFound
+	}
+	return nil
+}
+
+// PurgeExpired removes the drafts unchanged for longer than the configured
+// retention, and returns how many it removed.
+func (s *ArticleDraftService) PurgeExpired(ctx context.Context) (int64, error) {
+	cfg, err := sysconfig.LoadArticleDraftConfig(s.db)
+	if err != nil {
+		return 0, err
+	}
+	if cfg.RetentionDays <= 0 {
+		return 0, nil
+	}
+	return s.repo.DeleteChangedBefore(ctx, s.now().AddDate(0, 0, -cfg.RetentionDays))
+}
+
+// detectConflict sets the draft's conflict when others posted to the
+// ticket after its base article.
+func (s *ArticleDraftService) detectConflict(ctx context.Context, draft *models.ArticleDraft) error {
+	conflict, err := s.repo.ArticlesSince(ctx, draft.TicketID, draft.BaseArticleID, draft.UserID)
+	if err != nil {
+		return err
+	}
+	draft.Conflict = conflict
+	return nil
+}
+
+func validateArticleDraft(draft *models.ArticleDraft) error {
+	if len(draft.Subject) > articleDraftMaxSubject {
+		return fmt.Errorf("%w: subject must be at most %d characters", ErrArticleDraftInvalid, articleDraftMaxSubject)
+	}
+	if len(draft.Body) > articleDraftMaxBody {
+		return fmt.Errorf("%w: body must be at most %d bytes", ErrArticleDraftInvalid, articleDraftMaxBody)
+	}
+	if draft.BaseArticleID < 0 || draft.Version < 0 {
+		return fmt.Errorf("%w: base_article_id and version cannot be negative", ErrArticleDraftInvalid)
+	}
+	if draft.ContentType == "" {
+		draft.ContentType = "text/plain"
+	}
+	return nil
+}
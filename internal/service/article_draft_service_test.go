@@ -0,0 +1,107 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/sysconfig"
+	"github.com/goatkit/goatflow/internal/testutil"
+)
+
+func TestArticleDraftService(t *testing.T) {
+	db := testutil.UseMigratedDB(t)
+	for id, login := range map[int]string{1: "root@localhost", 2: "agent", 3: "other"} {
+		_, err := db.Exec(`INSERT INTO users (id, login, pw, first_name, last_name, valid_id, create_time, create_by, change_time, change_by)
+			VALUES (?, ?, 'x', 'Test', 'Agent', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`, id, login)
+		require.NoError(t, err)
+	}
+	_, err := db.Exec(`INSERT INTO ticket (id, tn, title, queue_id, ticket_lock_id, user_id, responsible_user_id,
+		ticket_priority_id, ticket_state_id, timeout, until_time, escalation_time, escalation_update_time,
+		escalation_response_time, escalation_solution_time, archive_flag, create_time, create_by, change_time, change_by)
+		VALUES (1, '2026031010000001', 'Mail down', 1, 1, 1, 1, 3, 1, 0, 0, 0, 0, 0, 0, 0,
+		        CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`)
+	require.NoError(t, err)
+	addArticle := func(id, by int) {
+		_, err := db.Exec(`INSERT INTO article (id, ticket_id, article_sender_type_id, communication_channel_id,
+			is_visible_for_customer, create_time, create_by, change_time, change_by)
+			VALUES (?, 1, 1, 1, 1, CURRENT_TIMESTAMP, ?, CURRENT_TIMESTAMP, ?)`, id, by, by)
+		require.NoError(t, err)
+	}
+	addArticle(1, 1)
+
+	ctx := context.Background()
+	s := NewArticleDraftService(db)
+	clock := time.Date(2026, 3, 10, 9, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return clock }
+
+	assert.ErrorIs(t, s.Save(ctx, &models.ArticleDraft{TicketID: 99, Body: "x"}, 2), ErrArticleDraftTicketNotFound)
+	assert.ErrorIs(t, s.Save(ctx, &models.ArticleDraft{TicketID: 1, Version: -1}, 2), ErrArticleDraftInvalid)
+
+	draft := &models.ArticleDraft{TicketID: 1, Subject: "Re: Mail down", Body: "Restarting"}
+	require.NoError(t, s.Save(ctx, draft, 2))
+	assert.Equal(t, 1, draft.Version)
+	assert.Equal(t, int64(1), draft.BaseArticleID, "new drafts start from the newest article")
+	assert.Equal(t, "text/plain", draft.ContentType)
+	assert.Nil(t, draft.Conflict)
+
+	autosave := &models.ArticleDraft{TicketID: 1, Body: "Restarting the server", Version: 1}
+	require.NoError(t, s.Save(ctx, autosave, 2))
+	assert.Equal(t, draft.ID, autosave.ID)
+	assert.Equal(t, 2, autosave.Version)
+	assert.Equal(t, int64(1), autosave.BaseArticleID, "the stored base is kept")
+	assert.ErrorIs(t, s.Save(ctx, &models.ArticleDraft{TicketID: 1, Body: "stale tab", Version: 1}, 2),
+		ErrArticleDraftVersionConflict)
+
+	addArticle(2, 2)
+	mine, err := s.Mine(ctx, 1, 2)
+	require.NoError(t, err)
+	assert.Nil(t, mine.Conflict, "the author's own articles don't conflict")
+	addArticle(3, 1)
+	mine, err = s.Mine(ctx, 1, 2)
+	require.NoError(t, err)
+	require.NotNil(t, mine.Conflict, "another agent posted first")
+	assert.Equal(t, int64(3), mine.Conflict.LatestArticleID)
+	assert.Equal(t, 1, mine.Conflict.LatestBy)
+	ack := &models.ArticleDraft{TicketID: 1, Body: mine.Body, BaseArticleID: mine.Conflict.LatestArticleID}
+	require.NoError(t, s.Save(ctx, ack, 2))
+	assert.Nil(t, ack.Conflict, "sending the latest article as base acknowledges the conflict")
+
+	_, err = s.Get(ctx, draft.ID, 3)
+	assert.ErrorIs(t, err, ErrArticleDraftNotFound, "private drafts are hidden from others")
+	assert.ErrorIs(t, s.Update(ctx, &models.ArticleDraft{ID: draft.ID, Body: "x", Version: ack.Version}, 3), ErrArticleDraftNotFound)
+	share := &models.ArticleDraft{TicketID: 1, Body: mine.Body, Shared: true}
+	require.NoError(t, s.Save(ctx, share, 2))
+
+	drafts, err := s.List(ctx, 1, 3)
+	require.NoError(t, err)
+	require.Len(t, drafts, 1)
+	assert.True(t, drafts[0].Shared)
+	assert.ErrorIs(t, s.Update(ctx, &models.ArticleDraft{ID: draft.ID, Body: "x"}, 3), ErrArticleDraftInvalid, "version is required")
+	edit := &models.ArticleDraft{ID: draft.ID, Body: "Restarted, checking queues", Version: share.Version}
+	require.NoError(t, s.Update(ctx, edit, 3))
+	assert.True(t, edit.Shared, "co-editors cannot unshare")
+	assert.Equal(t, 2, edit.UserID)
+	assert.Equal(t, 3, edit.ChangeBy)
+	assert.ErrorIs(t, s.Save(ctx, &models.ArticleDraft{TicketID: 1, Body: "mine", Version: share.Version}, 2),
+		ErrArticleDraftVersionConflict, "the author sees the co-editor's save")
+
+	clock = clock.AddDate(0, 0, 31)
+	n, err := s.PurgeExpired(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n, "drafts expire after the default 30 days")
+
+	require.NoError(t, s.Save(ctx, &models.ArticleDraft{TicketID: 1, Body: "again"}, 2))
+	require.NoError(t, sysconfig.SaveArticleDraftConfig(db, sysconfig.ArticleDraftConfig{RetentionDays: 0}, 1))
+	clock = clock.AddDate(1, 0, 0)
+	n, err = s.PurgeExpired(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, n, "retention 0 keeps drafts")
+
+	require.NoError(t, s.Discard(ctx, 1, 2))
+	assert.ErrorIs(t, s.Discard(ctx, 1, 2), ErrArticleDraftNotFound)
+}
@@ -0,0 +1,249 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/goatkit/goatflow/internal/antivirus"
+	"github.com/goatkit/goatflow/internal/config"
+	"github.com/goatkit/goatflow/internal/mailqueue"
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/repository"
+)
+
+// Attachment scan errors.
+var (
+	// ErrAttachmentQuarantined is returned by Check when an attachment
+	// was quarantined instead of being stored.
+	ErrAttachmentQuarantined  = errors.New("attachment quarantined by antivirus scan")
+	ErrQuarantineNotFound     = errors.New("quarantined attachment not found")
+	ErrQuarantineReleased     = repository.ErrQuarantineReleased
+	ErrAttachmentScanDisabled = errors.New("attachment scanning is disabled")
+)
+
+// AttachmentScanInput is an attachment about to be stored with an article.
+type AttachmentScanInput struct {
+	TicketID    int
+	ArticleID   int
+	Filename    string
+	ContentType string
+	Content     []byte
+	Source      string // models.AttachmentSourceUpload or models.AttachmentSourceEmail
+	UserID      int
+}
+
+// AttachmentScanService scans attachments before they are stored and
+// manages the quarantine of those that must not be.
+type AttachmentScanService struct {
+	repo              *repository.AttachmentScanRepository
+	scanner           antivirus.Scanner
+	quarantineOnError bool
+	mail              *mailqueue.MailQueueRepository
+	from              string
+	now               func() time.Time
+}
+
+// NewAttachmentScanService creates an attachment scan service. With a nil
+// scanner attachments are not scanned, but the quarantine can still be
+// managed.
+func NewAttachmentScanService(db *sql.DB, scanner antivirus.Scanner) *AttachmentScanService {
+	return &AttachmentScanService{
+		repo:    repository.NewAttachmentScanRepository(db),
+		scanner: scanner,
+		now:     time.Now,
+	}
+}
+
+// NewDefaultAttachmentScanService creates the service for antivirus.Default
+// with the storage.attachments.scan settings.
+func NewDefaultAttachmentScanService(db *sql.DB) *AttachmentScanService {
+	s := NewAttachmentScanService(db, antivirus.Default())
+	if cfg := config.Get(); cfg != nil {
+		scan := cfg.Storage.Attachments.Scan
+		s.QuarantineOnError(strings.EqualFold(scan.OnError, "quarantine"))
+		if scan.NotifyAdmins {
+			s.WithEmail(mailqueue.NewMailQueueRepository(db), cfg.Email.From)
+		}
+	}
+	return s
+}
+
+// QuarantineOnError makes attachments the scanner could not check go to
+// quarantine instead of being stored with scan status error.
+func (s *AttachmentScanService) QuarantineOnError(on bool) *AttachmentScanService {
+	s.quarantineOnError = on
+	return s
+}
+
+// WithEmail makes the service email the admin group about infected
+// attachments, from the address.
+func (s *AttachmentScanService) WithEmail(queue *mailqueue.MailQueueRepository, from string) *AttachmentScanService {
+	s.mail = queue
+	s.from = from
+	return s
+}
+
+// Enabled reports whether attachments are scanned.
+func (s *AttachmentScanService) Enabled() bool {
+	return s != nil && s.scanner != nil
+}
+
+// Check scans an attachment before it is stored. A clean attachment gets a
+// scan record and nil is returned. An infected one is quarantined, its
+// article flagged and the admins notified; Check then returns
+// ErrAttachmentQuarantined. Callers must not store the attachment when Check
+// returns an error. Check does nothing when scanning is off.
+func (s *AttachmentScanService) Check(ctx context.Context, in AttachmentScanInput) (*models.AttachmentScan, error) {
+	if !s.Enabled() {
+		return nil, nil
+	}
+	scan := &models.AttachmentScan{
+		ArticleID: int64(in.ArticleID),
+		Filename:  in.Filename,
+		Scanner:   s.scanner.Name(),
+		Source:    in.Source,
+		ScannedAt: s.now(),
+	}
+	result, err := s.scanner.Scan(ctx, bytes.NewReader(in.Content))
+	switch {
+	case err != nil:
+		log.Printf("antivirus: scanning %q of article %d failed: %v", in.Filename, in.ArticleID, err)
+		scan.Status, scan.Signature = models.ScanStatusError, err.Error()
+		if s.quarantineOnError {
+			return scan, s.quarantine(ctx, in, scan)
+		}
+	case result.Infected():
+		scan.Status, scan.Signature = models.ScanStatusInfected, result.Signature
+		return scan, s.quarantine(ctx, in, scan)
+	default:
+		scan.Status = models.ScanStatusClean
+	}
+	if err := s.repo.RecordScan(ctx, scan); err != nil {
+		log.Printf("antivirus: recording scan of %q failed: %v", in.Filename, err)
+	}
+	return scan, nil
+}
+
+// quarantine holds the attachment back. Infected attachments also flag
+// their article and notify the admins.
+func (s *AttachmentScanService) quarantine(ctx context.Context, in AttachmentScanInput, scan *models.AttachmentScan) error {
+	q := &models.QuarantinedAttachment{
+		TicketID:    int64(in.TicketID),
+		ArticleID:   int64(in.ArticleID),
+		Filename:    in.Filename,
+		ContentType: in.ContentType,
+		Content:     in.Content,
+		Status:      scan.Status,
+		Signature:   scan.Signature,
+		Scanner:     scan.Scanner,
+		Source:      in.Source,
+		CreateTime:  scan.ScannedAt,
+		CreateBy:    in.UserID,
+	}
+	if err := s.repo.Quarantine(ctx, q); err != nil {
+		return fmt.Errorf("%w: %v", ErrAttachmentQuarantined, err)
+	}
+	log.Printf("antivirus: quarantined %q of article %d (%s: %s)", in.Filename, in.ArticleID, scan.Status, scan.Signature)
+	if scan.Status != models.ScanStatusInfected {
+		return ErrAttachmentQuarantined
+	}
+
+	if err := s.repo.FlagArticle(ctx, q.ArticleID, repository.ArticleFlagVirusDetected, scan.Signature, in.UserID, scan.ScannedAt); err != nil {
+		log.Printf("antivirus: flagging article %d failed: %v", in.ArticleID, err)
+	}
+	if s.mail != nil {
+		s.notifyAdmins(ctx, q)
+	}
+	return ErrAttachmentQuarantined
+}
+
+// notifyAdmins queues an email about an infected attachment to each admin.
+// Failures are only logged; the attachment is quarantined either way.
+func (s *AttachmentScanService) notifyAdmins(ctx context.Context, q *models.QuarantinedAttachment) {
+	recipients, err := s.repo.AdminEmails(ctx)
+	if err != nil {
+		log.Printf("antivirus: loading admins failed: %v", err)
+		return
+	}
+	tn, err := s.repo.TicketNumber(ctx, q.TicketID)
+	if err != nil {
+		log.Printf("antivirus: loading ticket %d failed: %v", q.TicketID, err)
+	}
+	if tn == "" {
+		tn = fmt.Sprint(q.TicketID)
+	}
+	subject := fmt.Sprintf("[Ticket#%s] Infected attachment quarantined", tn)
+	body := fmt.Sprintf("The attachment %q of ticket %s (article %d, %s) contains %s.\n\n"+
+		"It was not added to the article and is held in quarantine entry %d.\n",
+		q.Filename, tn, q.ArticleID, q.Source, q.Signature, q.ID)
+	for _, to := range recipients {
+		sender := s.from
+		item := &mailqueue.MailQueueItem{
+			Sender:     &sender,
+			Recipient:  to,
+			RawMessage: mailqueue.BuildEmailMessage(s.from, to, subject, body),
+			CreateTime: s.now(),
+		}
+		if err := s.mail.Insert(ctx, item); err != nil {
+			log.Printf("antivirus: queueing notification for %s failed: %v", to, err)
+		}
+	}
+}
+
+// ListQuarantine returns quarantined attachments, newest first.
+func (s *AttachmentScanService) ListQuarantine(ctx context.Context, includeReleased bool, limit, offset int) ([]*models.QuarantinedAttachment, error) {
+	return s.repo.ListQuarantine(ctx, includeReleased, limit, offset)
+}
+
+// GetQuarantine returns a quarantined attachment.
+func (s *AttachmentScanService) GetQuarantine(ctx context.Context, id int64) (*models.QuarantinedAttachment, error) {
+	q, err := s.repo.GetQuarantine(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if q == nil {
+		return nil, ErrQuarantineNotFound
+	}
+	return q, nil
+}
+
+// Release adds a quarantined attachment to its article, for files an admin
+// judged to be false positives.
+func (s *AttachmentScanService) Release(ctx context.Context, id int64, userID int) (*models.QuarantinedAttachment, error) {
+	if _, err := s.GetQuarantine(ctx, id); err != nil {
+		return nil, err
+	}
+	if err := s.repo.Release(ctx, id, userID, s.now()); err != nil {
+		return nil, err
+	}
+	log.Printf("antivirus: quarantine entry %d released by user %d", id, userID)
+	return s.GetQuarantine(ctx, id)
+}
+
+// DeleteQuarantine deletes a quarantined attachment.
+func (s *AttachmentScanService) DeleteQuarantine(ctx context.Context, id int64) error {
+	deleted, err := s.repo.DeleteQuarantine(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrQuarantineNotFound
+	}
+	return nil
+}
+
+// ScannerVersion checks that the scanner can be reached and returns its
+// name and version.
+func (s *AttachmentScanService) ScannerVersion(ctx context.Context) (string, string, error) {
+	if !s.Enabled() {
+		return "", "", ErrAttachmentScanDisabled
+	}
+	version, err := s.scanner.Ping(ctx)
+	return s.scanner.Name(), version, err
+}
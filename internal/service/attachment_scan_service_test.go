@@ -0,0 +1,94 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/antivirus"
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/testutil"
+)
+
+// stubScanner reports content containing "EICAR" as infected and fails
+// when err is set.
+type stubScanner struct{ err error }
+
+func (s *stubScanner) Name() string { return "stub" }
+
+func (s *stubScanner) Scan(_ context.Context, r io.Reader) (*antivirus.Result, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if strings.Contains(string(data), "EICAR") {
+		return &antivirus.Result{Status: antivirus.StatusInfected, Signature: "Eicar-Test-Signature"}, nil
+	}
+	return &antivirus.Result{Status: antivirus.StatusClean}, nil
+}
+
+func (s *stubScanner) Ping(context.Context) (string, error) { return "stub 1.0", s.err }
+
+func TestAttachmentScanService_Check(t *testing.T) {
+	db := testutil.UseMigratedDB(t)
+	scanner := &stubScanner{}
+	svc := NewAttachmentScanService(db, scanner)
+	svc.now = func() time.Time { return time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC) }
+	ctx := context.Background()
+	in := AttachmentScanInput{TicketID: 1, ArticleID: 10, Filename: "report.pdf", ContentType: "application/pdf",
+		Content: []byte("%PDF"), Source: models.AttachmentSourceUpload, UserID: 1}
+
+	scan, err := svc.Check(ctx, in)
+	require.NoError(t, err)
+	assert.Equal(t, models.ScanStatusClean, scan.Status)
+
+	in.Filename, in.Content = "invoice.exe", []byte("X5O!P%@AP EICAR")
+	scan, err = svc.Check(ctx, in)
+	assert.ErrorIs(t, err, ErrAttachmentQuarantined)
+	assert.Equal(t, models.ScanStatusInfected, scan.Status)
+	var flag string
+	require.NoError(t, db.QueryRow("SELECT article_value FROM article_flag WHERE article_id = 10 AND article_key = 'VirusDetected'").Scan(&flag))
+	assert.Equal(t, "Eicar-Test-Signature", flag)
+
+	// Scanner failures store the attachment unless configured otherwise.
+	scanner.err = errors.New("clamd: connect: refused")
+	in.Filename = "notes.txt"
+	scan, err = svc.Check(ctx, in)
+	require.NoError(t, err)
+	assert.Equal(t, models.ScanStatusError, scan.Status)
+	svc.QuarantineOnError(true)
+	_, err = svc.Check(ctx, in)
+	assert.ErrorIs(t, err, ErrAttachmentQuarantined)
+
+	list, err := svc.ListQuarantine(ctx, false, 50, 0)
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	assert.Equal(t, models.ScanStatusError, list[0].Status)
+	assert.Equal(t, "invoice.exe", list[1].Filename)
+
+	require.NoError(t, svc.DeleteQuarantine(ctx, list[0].ID))
+	assert.ErrorIs(t, svc.DeleteQuarantine(ctx, list[0].ID), ErrQuarantineNotFound)
+	_, err = svc.Release(ctx, list[0].ID, 1)
+	assert.ErrorIs(t, err, ErrQuarantineNotFound)
+}
+
+func TestAttachmentScanService_Disabled(t *testing.T) {
+	var svc *AttachmentScanService
+	scan, err := svc.Check(context.Background(), AttachmentScanInput{Content: []byte("EICAR")})
+	require.NoError(t, err)
+	assert.Nil(t, scan)
+
+	svc = NewAttachmentScanService(testutil.UseMigratedDB(t), nil)
+	assert.False(t, svc.Enabled())
+	_, _, err = svc.ScannerVersion(context.Background())
+	assert.ErrorIs(t, err, ErrAttachmentScanDisabled)
+}
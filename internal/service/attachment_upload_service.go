@@ -0,0 +1,298 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/goatkit/goatflow/internal/config"
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/repository"
+	"github.com/goatkit/goatflow/internal/sysconfig"
+)
+
+// Errors returned by AttachmentUploadService.
+var (
+	ErrAttachmentTooLarge       = errors.New("attachment is too large")
+	ErrAttachmentTypeNotAllowed = errors.New("attachment type is not allowed")
+	ErrAttachmentTicketTotal    = errors.New("ticket attachment total would be exceeded")
+	ErrAttachmentPolicySettings = errors.New("invalid attachment policy")
+	ErrAttachmentUploadNotFound = errors.New("upload not found")
+	ErrAttachmentUploadInvalid  = errors.New("invalid upload")
+	ErrAttachmentUploadOffset   = errors.New("chunk does not continue the upload")
+	ErrAttachmentUploadPending  = errors.New("upload is not complete")
+)
+
+// AttachmentPolicyError is returned when an attachment breaks the policy of
+// the uploader's role. It matches ErrAttachmentTooLarge,
+// ErrAttachmentTypeNotAllowed or ErrAttachmentTicketTotal.
+type AttachmentPolicyError struct {
+	Err         error
+	Limit       int64  // The size limit that was hit, in bytes
+	ContentType string // The type that is not allowed
+}
+
+func (e *AttachmentPolicyError) Error() string {
+	switch e.Err {
+	case ErrAttachmentTypeNotAllowed:
+		return fmt.Sprintf("%v: %s", e.Err, e.ContentType)
+	default:
+		return fmt.Sprintf("%v (limit %d bytes)", e.Err, e.Limit)
+	}
+}
+
+func (e *AttachmentPolicyError) Unwrap() error { return e.Err }
+
+// Attachment policy roles.
+const (
+	AttachmentRoleAdmin    = "Admin"
+	AttachmentRoleAgent    = "Agent"
+	AttachmentRoleCustomer = "Customer"
+)
+
+const (
+	// Limits when neither the configuration file nor a policy sets one.
+	defaultAttachmentMaxFileSize    = 10 * 1024 * 1024
+	defaultAttachmentMaxTicketTotal = 50 * 1024 * 1024
+
+	// AttachmentUploadMaxChunk is the largest chunk one request may send.
+	AttachmentUploadMaxChunk = 8 * 1024 * 1024
+
+	// attachmentUploadMaxAge is how long an upload may go without a chunk
+	// before it is removed.
+	attachmentUploadMaxAge = 24 * time.Hour
+)
+
+// AttachmentUploadStart describes a chunked upload to start.
+type AttachmentUploadStart struct {
+	TicketID    int    `json:"-"`
+	ArticleID   int    `json:"article_id"`
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+}
+
+// AttachmentUploadService applies the per-role attachment upload policies
+// and keeps chunked uploads of large files until they can be attached.
+//
+// A policy limits the size of one attachment, its MIME types and how large
+// all attachments of a ticket may get. The storage.attachments settings of
+// the configuration file are the base; the sysconfig policies override them
+// by default and per role.
+type AttachmentUploadService struct {
+	db   *sql.DB
+	repo *repository.AttachmentUploadRepository
+	now  func() time.Time
+}
+
+// NewAttachmentUploadService creates an attachment upload service.
+func NewAttachmentUploadService(db *sql.DB) *AttachmentUploadService {
+	return &AttachmentUploadService{
+		db:   db,
+		repo: repository.NewAttachmentUploadRepository(db),
+		now:  time.Now,
+	}
+}
+
+// Settings returns the attachment policies.
+func (s *AttachmentUploadService) Settings() (sysconfig.AttachmentPolicyConfig, error) {
+	return sysconfig.LoadAttachmentPolicyConfig(s.db)
+}
+
+// SaveSettings validates and stores the attachment policies.
+func (s *AttachmentUploadService) SaveSettings(cfg sysconfig.AttachmentPolicyConfig, userID int) (sysconfig.AttachmentPolicyConfig, error) {
+	policies := map[string]sysconfig.AttachmentPolicy{"default": cfg.Default}
+	for role, p := range cfg.Roles {
+		switch role {
+		case AttachmentRoleAdmin, AttachmentRoleAgent, AttachmentRoleCustomer:
+		default:
+			return cfg, fmt.Errorf("%w: unknown role %q, use Admin, Agent or Customer", ErrAttachmentPolicySettings, role)
+		}
+		policies[role] = p
+	}
+	for name, p := range policies {
+		if p.MaxFileSize < 0 || p.MaxTicketTotal < 0 {
+			return cfg, fmt.Errorf("%w: %s sizes must not be negative", ErrAttachmentPolicySettings, name)
+		}
+		for _, t := range p.AllowedTypes {
+			if i := strings.Index(t, "/"); i <= 0 || i == len(t)-1 {
+				return cfg, fmt.Errorf("%w: %s: %q is not a MIME type", ErrAttachmentPolicySettings, name, t)
+			}
+		}
+	}
+	if err := sysconfig.SaveAttachmentPolicyConfig(s.db, cfg, userID); err != nil {
+		return cfg, err
+	}
+	return s.Settings()
+}
+
+// Policy returns the attachment policy of a role.
+func (s *AttachmentUploadService) Policy(role string) (sysconfig.AttachmentPolicy, error) {
+	base := sysconfig.AttachmentPolicy{
+		MaxFileSize:    defaultAttachmentMaxFileSize,
+		MaxTicketTotal: defaultAttachmentMaxTicketTotal,
+	}
+	if cfg := config.Get(); cfg != nil {
+		if cfg.Storage.Attachments.MaxSize > 0 {
+			base.MaxFileSize = cfg.Storage.Attachments.MaxSize
+		}
+		base.AllowedTypes = cfg.Storage.Attachments.AllowedTypes
+	}
+	settings, err := s.Settings()
+	if err != nil {
+		return base, err
+	}
+	return settings.For(role, base), nil
+}
+
+// Check tells whether an attachment of the size and type may be added to
+// the ticket under the policy. Only the size counts toward the ticket total
+// of a ticket that does not exist yet, with ticketID 0.
+func (s *AttachmentUploadService) Check(ctx context.Context, policy sysconfig.AttachmentPolicy, ticketID int, contentType string, size int64) error {
+	if policy.MaxFileSize > 0 && size > policy.MaxFileSize {
+		return &AttachmentPolicyError{Err: ErrAttachmentTooLarge, Limit: policy.MaxFileSize}
+	}
+	if !policy.AllowsType(contentType) {
+		return &AttachmentPolicyError{Err: ErrAttachmentTypeNotAllowed, ContentType: contentType}
+	}
+	if policy.MaxTicketTotal <= 0 {
+		return nil
+	}
+	total := size
+	if ticketID > 0 && s.db != nil {
+		existing, err := s.repo.TicketAttachmentSize(ctx, ticketID)
+		if err != nil {
+			return err
+		}
+		total += existing
+	}
+	if total > policy.MaxTicketTotal {
+		return &AttachmentPolicyError{Err: ErrAttachmentTicketTotal, Limit: policy.MaxTicketTotal}
+	}
+	return nil
+}
+
+// Start starts a chunked upload for the ticket, after checking the file
+// against the role's policy. Abandoned uploads are removed on the way.
+func (s *AttachmentUploadService) Start(ctx context.Context, in AttachmentUploadStart, userID int, role string) (*models.AttachmentUpload, error) {
+	name := strings.TrimSpace(filepath.Base(strings.ReplaceAll(in.Filename, "\\", "/")))
+	if name == "" || name == "." || name == "/" {
+		return nil, fmt.Errorf("%w: filename is required", ErrAttachmentUploadInvalid)
+	}
+	if in.TicketID <= 0 {
+		return nil, fmt.Errorf("%w: ticket is required", ErrAttachmentUploadInvalid)
+	}
+	if in.Size <= 0 {
+		return nil, fmt.Errorf("%w: size must be positive", ErrAttachmentUploadInvalid)
+	}
+	policy, err := s.Policy(role)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.Check(ctx, policy, in.TicketID, in.ContentType, in.Size); err != nil {
+		return nil, err
+	}
+	if n, err := s.repo.DeleteStale(ctx, s.now().Add(-attachmentUploadMaxAge)); err != nil {
+		log.Printf("attachment uploads: removing abandoned uploads failed: %v", err)
+	} else if n > 0 {
+		log.Printf("attachment uploads: removed %d abandoned upload(s)", n)
+	}
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, fmt.Errorf("generate upload id: %w", err)
+	}
+	now := s.now()
+	u := &models.AttachmentUpload{
+		ID:          hex.EncodeToString(id),
+		TicketID:    in.TicketID,
+		ArticleID:   in.ArticleID,
+		Filename:    name,
+		ContentType: in.ContentType,
+		TotalSize:   in.Size,
+		UserID:      userID,
+		UserRole:    role,
+		CreateTime:  now,
+		ChangeTime:  now,
+	}
+	if err := s.repo.Create(ctx, u); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// Get returns an upload of the user.
+func (s *AttachmentUploadService) Get(ctx context.Context, id string, userID int) (*models.AttachmentUpload, error) {
+	u, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	// Other users' uploads are not found, so their IDs cannot be probed
+	if u == nil || u.UserID != userID {
+		return nil, ErrAttachmentUploadNotFound
+	}
+	return u, nil
+}
+
+// Append adds a chunk at offset, which must be where the upload currently
+// ends. A chunk that was already received, e.g. when a client resends after
+// a lost response, gives ErrAttachmentUploadOffset; the client resumes from
+// the upload's received size.
+func (s *AttachmentUploadService) Append(ctx context.Context, id string, userID int, offset int64, chunk []byte) (*models.AttachmentUpload, error) {
+	u, err := s.Get(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(chunk) == 0 {
+		return nil, fmt.Errorf("%w: empty chunk", ErrAttachmentUploadInvalid)
+	}
+	if len(chunk) > AttachmentUploadMaxChunk {
+		return nil, fmt.Errorf("%w: chunks may be at most %d bytes", ErrAttachmentUploadInvalid, AttachmentUploadMaxChunk)
+	}
+	if offset+int64(len(chunk)) > u.TotalSize {
+		return nil, fmt.Errorf("%w: chunk ends after the announced size", ErrAttachmentUploadInvalid)
+	}
+	if err := s.repo.AppendChunk(ctx, id, offset, chunk, s.now()); err != nil {
+		if errors.Is(err, repository.ErrUploadOffset) {
+			return u, ErrAttachmentUploadOffset
+		}
+		return nil, err
+	}
+	u.ReceivedSize = offset + int64(len(chunk))
+	return u, nil
+}
+
+// Assemble returns the upload and its content once all chunks arrived. The
+// caller checks and attaches the file, then calls Discard.
+func (s *AttachmentUploadService) Assemble(ctx context.Context, id string, userID int) (*models.AttachmentUpload, []byte, error) {
+	u, err := s.Get(ctx, id, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if u.ReceivedSize < u.TotalSize {
+		return u, nil, ErrAttachmentUploadPending
+	}
+	content, err := s.repo.Content(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if int64(len(content)) != u.TotalSize {
+		return nil, nil, fmt.Errorf("upload %s has %d of %d bytes stored", id, len(content), u.TotalSize)
+	}
+	return u, content, nil
+}
+
+// Discard removes an upload of the user and its chunks.
+func (s *AttachmentUploadService) Discard(ctx context.Context, id string, userID int) error {
+	if _, err := s.Get(ctx, id, userID); err != nil {
+		return err
+	}
+	return s.repo.Delete(ctx, id)
+}
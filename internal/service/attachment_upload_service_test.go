@@ -0,0 +1,128 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/sysconfig"
+	"github.com/goatkit/goatflow/internal/testutil"
+)
+
+func TestAttachmentPolicyConfig_For(t *testing.T) {
+	cfg := sysconfig.AttachmentPolicyConfig{
+		Default: sysconfig.AttachmentPolicy{MaxFileSize: 20 << 20},
+		Roles: map[string]sysconfig.AttachmentPolicy{
+			AttachmentRoleCustomer: {MaxFileSize: 5 << 20, AllowedTypes: []string{"image/*", "application/pdf"}},
+		},
+	}
+	base := sysconfig.AttachmentPolicy{MaxFileSize: 10 << 20, AllowedTypes: []string{"text/plain"}, MaxTicketTotal: 50 << 20}
+
+	agent := cfg.For(AttachmentRoleAgent, base)
+	assert.Equal(t, int64(20<<20), agent.MaxFileSize)
+	assert.Equal(t, []string{"text/plain"}, agent.AllowedTypes, "inherited from the configuration file")
+	assert.Equal(t, int64(50<<20), agent.MaxTicketTotal)
+
+	customer := cfg.For(AttachmentRoleCustomer, base)
+	assert.Equal(t, int64(5<<20), customer.MaxFileSize)
+	assert.True(t, customer.AllowsType("image/png"))
+	assert.True(t, customer.AllowsType("Application/PDF; name=x.pdf"))
+	assert.False(t, customer.AllowsType("text/plain"))
+	assert.True(t, customer.AllowsType("application/octet-stream"), "unknown types are left to the extension check")
+}
+
+func TestAttachmentUploadService(t *testing.T) {
+	db := testutil.UseMigratedDB(t)
+	_, err := db.Exec(`INSERT INTO ticket (id, tn, title, queue_id, ticket_lock_id, user_id, responsible_user_id,
+		ticket_priority_id, ticket_state_id, timeout, until_time, escalation_time, escalation_update_time,
+		escalation_response_time, escalation_solution_time, archive_flag, create_time, create_by, change_time, change_by)
+		VALUES (1, '2026101610000001', 'Logs', 1, 1, 1, 1, 3, 1, 0, 0, 0, 0, 0, 0, 0,
+		        CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`)
+	require.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO article (id, ticket_id, article_sender_type_id, communication_channel_id,
+		is_visible_for_customer, create_time, create_by, change_time, change_by)
+		VALUES (1, 1, 1, 1, 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`)
+	require.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO article_data_mime_attachment (article_id, filename, content_type, content_size, content,
+		create_time, create_by, change_time, change_by)
+		VALUES (1, 'old.log', 'text/plain', '60', ?, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`, bytes.Repeat([]byte("x"), 60))
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	clock := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	svc := NewAttachmentUploadService(db)
+	svc.now = func() time.Time { return clock }
+
+	_, err = svc.SaveSettings(sysconfig.AttachmentPolicyConfig{Roles: map[string]sysconfig.AttachmentPolicy{"Guest": {}}}, 1)
+	assert.ErrorIs(t, err, ErrAttachmentPolicySettings)
+	_, err = svc.SaveSettings(sysconfig.AttachmentPolicyConfig{Default: sysconfig.AttachmentPolicy{AllowedTypes: []string{"pdf"}}}, 1)
+	assert.ErrorIs(t, err, ErrAttachmentPolicySettings)
+	saved, err := svc.SaveSettings(sysconfig.AttachmentPolicyConfig{
+		Default: sysconfig.AttachmentPolicy{MaxFileSize: 50, MaxTicketTotal: 100},
+		Roles:   map[string]sysconfig.AttachmentPolicy{AttachmentRoleAdmin: {MaxFileSize: 80}},
+	}, 1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(80), saved.Roles[AttachmentRoleAdmin].MaxFileSize)
+
+	policy, err := svc.Policy(AttachmentRoleAgent)
+	require.NoError(t, err)
+	assert.Equal(t, int64(50), policy.MaxFileSize)
+	assert.ErrorIs(t, svc.Check(ctx, policy, 1, "text/plain", 51), ErrAttachmentTooLarge)
+	assert.ErrorIs(t, svc.Check(ctx, policy, 1, "text/plain", 41), ErrAttachmentTicketTotal, "60 bytes are attached already")
+	require.NoError(t, svc.Check(ctx, policy, 1, "text/plain", 40))
+	require.NoError(t, svc.Check(ctx, policy, 0, "text/plain", 50), "a new ticket has no attachments yet")
+
+	_, err = svc.Start(ctx, AttachmentUploadStart{TicketID: 1, Filename: "big.log", Size: 60}, 7, AttachmentRoleAgent)
+	assert.ErrorIs(t, err, ErrAttachmentTooLarge)
+	_, err = svc.Start(ctx, AttachmentUploadStart{TicketID: 1, Filename: "", Size: 10}, 7, AttachmentRoleAgent)
+	assert.ErrorIs(t, err, ErrAttachmentUploadInvalid)
+
+	u, err := svc.Start(ctx, AttachmentUploadStart{TicketID: 1, Filename: `C:\logs\new.log`, Size: 10}, 7, AttachmentRoleAgent)
+	require.NoError(t, err)
+	assert.Equal(t, "new.log", u.Filename)
+	assert.Len(t, u.ID, 32)
+
+	_, err = svc.Get(ctx, u.ID, 8)
+	assert.ErrorIs(t, err, ErrAttachmentUploadNotFound, "other users' uploads are not visible")
+	_, _, err = svc.Assemble(ctx, u.ID, 7)
+	assert.ErrorIs(t, err, ErrAttachmentUploadPending)
+
+	u, err = svc.Append(ctx, u.ID, 7, 0, []byte("0123"))
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), u.ReceivedSize)
+
+	// A resent chunk is refused and tells where to resume
+	u, err = svc.Append(ctx, u.ID, 7, 0, []byte("0123"))
+	assert.ErrorIs(t, err, ErrAttachmentUploadOffset)
+	assert.Equal(t, int64(4), u.ReceivedSize)
+	_, err = svc.Append(ctx, u.ID, 7, 4, []byte("4567890"))
+	assert.ErrorIs(t, err, ErrAttachmentUploadInvalid, "longer than announced")
+
+	_, err = svc.Append(ctx, u.ID, 7, 4, []byte("456789"))
+	require.NoError(t, err)
+	done, content, err := svc.Assemble(ctx, u.ID, 7)
+	require.NoError(t, err)
+	assert.Equal(t, "0123456789", string(content))
+	assert.Equal(t, int64(10), done.ReceivedSize)
+	require.NoError(t, svc.Discard(ctx, u.ID, 7))
+	_, err = svc.Get(ctx, u.ID, 7)
+	assert.ErrorIs(t, err, ErrAttachmentUploadNotFound)
+
+	// Uploads without a chunk for a day are removed when the next one starts
+	stale, err := svc.Start(ctx, AttachmentUploadStart{TicketID: 1, Filename: "a.log", Size: 5}, 7, AttachmentRoleAgent)
+	require.NoError(t, err)
+	_, err = svc.Append(ctx, stale.ID, 7, 0, []byte("ab"))
+	require.NoError(t, err)
+	clock = clock.Add(25 * time.Hour)
+	_, err = svc.Start(ctx, AttachmentUploadStart{TicketID: 1, Filename: "b.log", Size: 5}, 7, AttachmentRoleAgent)
+	require.NoError(t, err)
+	_, err = svc.Get(ctx, stale.ID, 7)
+	assert.ErrorIs(t, err, ErrAttachmentUploadNotFound)
+	var chunks int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM attachment_upload_chunk").Scan(&chunks))
+	assert.Zero(t, chunks)
+}
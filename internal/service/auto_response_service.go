@@ -0,0 +1,375 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"net/mail"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goatkit/goatflow/internal/config"
+	"github.com/goatkit/goatflow/internal/mailqueue"
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/notifications"
+	"github.com/goatkit/goatflow/internal/repository"
+)
+
+// Errors returned by AutoResponseService.
+var (
+	ErrAutoResponseNotFound      = errors.New("auto response not found")
+	ErrAutoResponseQueueNotFound = errors.New("queue not found")
+	ErrAutoResponseInvalid       = errors.New("invalid auto response")
+)
+
+// AutoResponseLoopHeader marks auto responses this system sent, next to the
+// standard Auto-Submitted (RFC 3834) and Precedence headers, so they are
+// never answered again when they come back.
+const AutoResponseLoopHeader = "X-GoatFlow-Loop"
+
+var autoResponsePlaceholder = regexp.MustCompile(`(?i)(<|&lt;)(?:OTRS|GOATFLOW)_[A-Za-z0-9_]+(>|&gt;)`)
+
+// autoResponseMailQueue queues auto responses, normally a
+// *mailqueue.MailQueueRepository.
+type autoResponseMailQueue interface {
+	Insert(ctx context.Context, item *mailqueue.MailQueueItem) error
+}
+
+// AutoResponseInput describes an inbound email to answer automatically.
+type AutoResponseInput struct {
+	TypeID    int    // models.AutoResponseType*
+	TicketID  int    // Ticket the email was stored in
+	QueueID   int    // Queue whose auto responses apply
+	To        string // Address to answer: Reply-To, else From
+	Subject   string // Subject of the inbound email
+	MessageID string // Message-ID of the inbound email, for threading
+
+	// Automated is set when the inbound email was itself generated
+	// automatically (Auto-Submitted, Precedence bulk, ...); those are never
+	// answered.
+	Automated bool
+}
+
+// AutoResponseService manages auto responses and their assignment to
+// queues, and sends them for inbound email through the mail queue.
+type AutoResponseService struct {
+	repo     *repository.AutoResponseRepository
+	loops    *EmailLoopService
+	branding *BrandingService
+	mail     autoResponseMailQueue
+	now      func() time.Time
+}
+
+// NewAutoResponseService creates an auto response service.
+func NewAutoResponseService(db *sql.DB) *AutoResponseService {
+	return &AutoResponseService{
+		repo:     repository.NewAutoResponseRepository(db),
+		loops:    NewEmailLoopService(db),
+		branding: NewBrandingService(db),
+		mail:     mailqueue.NewMailQueueRepository(db),
+		now:      time.Now,
+	}
+}
+
+// List returns all auto responses with their queues.
+func (s *AutoResponseService) List(ctx context.Context) ([]*models.AutoResponse, error) {
+	return s.repo.List(ctx)
+}
+
+// Get returns an auto response with its queues.
+func (s *AutoResponseService) Get(ctx context.Context, id int) (*models.AutoResponse, error) {
+	ar, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if ar == nil {
+		return nil, ErrAutoResponseNotFound
+	}
+	return ar, nil
+}
+
+// Types returns the events auto responses can be sent for.
+func (s *AutoResponseService) Types(ctx context.Context) ([]models.AutoResponseType, error) {
+	return s.repo.ListTypes(ctx)
+}
+
+// Create validates and stores a new auto response.
+func (s *AutoResponseService) Create(ctx context.Context, ar *models.AutoResponse, userID int) error {
+	if err := s.validate(ctx, ar); err != nil {
+		return err
+	}
+	now := s.now()
+	ar.CreateTime, ar.CreateBy, ar.ChangeTime, ar.ChangeBy = now, userID, now, userID
+	if err := s.repo.Create(ctx, ar); err != nil {
+		return err
+	}
+	ar.QueueIDs = []int{}
+	return nil
+}
+
+// Update validates and stores the changes to an auto response.
+func (s *AutoResponseService) Update(ctx context.Context, ar *models.AutoResponse, userID int) error {
+	existing, err := s.Get(ctx, ar.ID)
+	if err != nil {
+		return err
+	}
+	if err := s.validate(ctx, ar); err != nil {
+		return err
+	}
+	if ar.TypeID != existing.TypeID && len(existing.QueueIDs) > 0 {
+		return fmt.Errorf("%w: the type of an auto response assigned to queues cannot change", ErrAutoResponseInvalid)
+	}
+	ar.CreateTime, ar.CreateBy, ar.QueueIDs = existing.CreateTime, existing.CreateBy, existing.QueueIDs
+	ar.ChangeTime, ar.ChangeBy = s.now(), userID
+	if err := s.repo.Update(ctx, ar); err == sql.ErrNoRows {
+		return ErrAutoResponseNotFound
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+// Delete deletes an auto response and removes it from its queues.
+func (s *AutoResponseService) Delete(ctx context.Context, id int) error {
+	deleted, err := s.repo.Delete(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrAutoResponseNotFound
+	}
+	return nil
+}
+
+// QueueResponses returns the auto responses assigned to a queue.
+func (s *AutoResponseService) QueueResponses(ctx context.Context, queueID int) ([]*models.AutoResponse, error) {
+	ok, err := s.repo.QueueExists(ctx, queueID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrAutoResponseQueueNotFound
+	}
+	return s.repo.ListForQueue(ctx, queueID)
+}
+
+// SetQueueResponses replaces the auto responses of a queue. A queue has at
+// most one auto response per type.
+func (s *AutoResponseService) SetQueueResponses(ctx context.Context, queueID int, ids []int, userID int) ([]*models.AutoResponse, error) {
+	ok, err := s.repo.QueueExists(ctx, queueID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrAutoResponseQueueNotFound
+	}
+	byType := make(map[int]string, len(ids))
+	for _, id := range ids {
+		ar, err := s.repo.Get(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if ar == nil {
+			return nil, fmt.Errorf("%w: auto response %d does not exist", ErrAutoResponseInvalid, id)
+		}
+		if other, dup := byType[ar.TypeID]; dup {
+			return nil, fmt.Errorf("%w: %q and %q are both of type %q", ErrAutoResponseInvalid, other, ar.Name, ar.TypeName)
+		}
+		byType[ar.TypeID] = ar.Name
+	}
+	if err := s.repo.ReplaceQueueResponses(ctx, queueID, ids, userID, s.now()); err != nil {
+		return nil, err
+	}
+	return s.repo.ListForQueue(ctx, queueID)
+}
+
+func (s *AutoResponseService) validate(ctx context.Context, ar *models.AutoResponse) error {
+	ar.Name = strings.TrimSpace(ar.Name)
+	ar.Subject = strings.TrimSpace(ar.Subject)
+	ar.Comments = strings.TrimSpace(ar.Comments)
+	if ar.Name == "" || len(ar.Name) > 200 {
+		return fmt.Errorf("%w: name is required and must be at most 200 characters", ErrAutoResponseInvalid)
+	}
+	if ar.Subject == "" {
+		return fmt.Errorf("%w: subject is required", ErrAutoResponseInvalid)
+	}
+	if strings.TrimSpace(ar.Body) == "" {
+		return fmt.Errorf("%w: body is required", ErrAutoResponseInvalid)
+	}
+	if len(ar.Comments) > 250 {
+		return fmt.Errorf("%w: comments must be at most 250 characters", ErrAutoResponseInvalid)
+	}
+	switch strings.ToLower(strings.TrimSpace(ar.ContentType)) {
+	case "text/html":
+		ar.ContentType = "text/html"
+	case "", "text/plain":
+		ar.ContentType = "text/plain"
+	default:
+		return fmt.Errorf("%w: content_type must be text/plain or text/html", ErrAutoResponseInvalid)
+	}
+	if ar.ValidID != 2 {
+		ar.ValidID = 1
+	}
+
+	types, err := s.repo.ListTypes(ctx)
+	if err != nil {
+		return err
+	}
+	known := false
+	for _, t := range types {
+		known = known || t.ID == ar.TypeID
+	}
+	if !known {
+		return fmt.Errorf("%w: unknown type_id %d", ErrAutoResponseInvalid, ar.TypeID)
+	}
+	ok, err := s.repo.SystemAddressExists(ctx, ar.SystemAddressID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("%w: unknown system_address_id %d", ErrAutoResponseInvalid, ar.SystemAddressID)
+	}
+	taken, err := s.repo.NameExists(ctx, ar.Name, ar.ID)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return fmt.Errorf("%w: an auto response named %q already exists", ErrAutoResponseInvalid, ar.Name)
+	}
+	return nil
+}
+
+// Respond queues the queue's auto response of the input's type, reporting
+// whether one was sent. Nothing is sent when the queue has none, or to
+// automated mail, the system's own addresses or invalid addresses, nor to
+// addresses that bounce or got the daily maximum of automatic emails.
+func (s *AutoResponseService) Respond(ctx context.Context, in AutoResponseInput) (bool, error) {
+	if in.Automated {
+		return false, nil
+	}
+	to, err := mail.ParseAddress(strings.TrimSpace(in.To))
+	if err != nil {
+		return false, nil
+	}
+	if own, err := s.repo.IsSystemAddress(ctx, to.Address); err != nil || own {
+		return false, err
+	}
+	ar, err := s.repo.ForQueue(ctx, in.QueueID, in.TypeID)
+	if err != nil || ar == nil {
+		return false, err
+	}
+	ticket, err := s.repo.Ticket(ctx, in.TicketID)
+	if err != nil || ticket == nil {
+		return false, err
+	}
+	reason, err := s.loops.Suppressed(ctx, to.Address)
+	if err != nil {
+		return false, err
+	}
+	if reason != "" {
+		log.Printf("auto response: not sending %q for ticket %s to %s: %s", ar.Name, ticket.TicketNumber, to.Address, reason)
+		return false, nil
+	}
+
+	fromEmail, fromName, err := s.repo.Sender(ctx, ar.SystemAddressID)
+	if err != nil {
+		return false, err
+	}
+	var emailCfg *config.EmailConfig
+	if cfg := config.Get(); cfg != nil {
+		emailCfg = &cfg.Email
+	}
+	fallbackEnvelope, fallbackHeader := notifications.DefaultFallbacks(emailCfg)
+	identity := &notifications.QueueIdentity{Email: fromEmail, DisplayName: fromName}
+	envelope := notifications.EnvelopeAddress(identity, fallbackEnvelope)
+	domain := notifications.DomainFromAddress(envelope)
+	if domain == "" {
+		domain = "goatflow.local"
+	}
+
+	vars := autoResponseVars(in, ticket, to.Address)
+	subject := renderAutoResponse(ar.Subject, vars, false)
+	if token := "[Ticket#" + ticket.TicketNumber + "]"; !strings.Contains(strings.ToLower(subject), strings.ToLower(token)) {
+		subject = token + " " + subject
+	}
+	body := renderAutoResponse(ar.Body, vars, ar.ContentType == "text/html")
+	if b, err := s.branding.Branding(ctx, ticket.CustomerID); err == nil {
+		body = notifications.ApplyEmailFrame(body, b.EmailHeader, b.EmailFooter)
+	}
+
+	headers := map[string]string{
+		"Message-ID":               mailqueue.GenerateMessageID(domain),
+		"Auto-Submitted":           "auto-replied",
+		"Precedence":               "bulk",
+		"X-Auto-Response-Suppress": "All",
+		"X-Loop":                   envelope,
+		AutoResponseLoopHeader:     "yes",
+	}
+	if in.MessageID != "" {
+		headers["In-Reply-To"] = in.MessageID
+		headers["References"] = in.MessageID
+	}
+	err = s.mail.Insert(ctx, &mailqueue.MailQueueItem{
+		Sender:     &envelope,
+		Recipient:  to.Address,
+		RawMessage: mailqueue.BuildEmailMessageWithHeaders(notifications.HeaderAddress(identity, fallbackHeader), to.Address, subject, body, headers),
+		CreateTime: s.now(),
+	})
+	if err != nil {
+		return false, err
+	}
+	if err := s.loops.RecordSent(ctx, to.Address); err != nil {
+		log.Printf("auto response: failed to record loop protection for %s: %v", to.Address, err)
+	}
+	log.Printf("auto response: queued %q (%s) for ticket %s to %s", ar.Name, ar.TypeName, ticket.TicketNumber, to.Address)
+	return true, nil
+}
+
+// autoResponseVars returns the placeholder values of an auto response,
+// keyed like the <OTRS_*> tags of agent templates.
+func autoResponseVars(in AutoResponseInput, t *repository.AutoResponseTicket, to string) map[string]string {
+	email := t.CustomerEmail
+	if email == "" {
+		email = to
+	}
+	realname := strings.TrimSpace(t.CustomerFirstname + " " + t.CustomerLastname)
+	if realname == "" {
+		realname = email
+	}
+	return map[string]string{
+		"TICKET_TicketNumber":    t.TicketNumber,
+		"TICKET_TicketID":        strconv.Itoa(in.TicketID),
+		"TICKET_Title":           t.Title,
+		"TICKET_Queue":           t.QueueName,
+		"TICKET_CustomerUserID":  t.CustomerUserID,
+		"CUSTOMER_SUBJECT":       in.Subject,
+		"CUSTOMER_REALNAME":      realname,
+		"CUSTOMER_UserFirstname": t.CustomerFirstname,
+		"CUSTOMER_UserLastname":  t.CustomerLastname,
+		"CUSTOMER_UserEmail":     email,
+	}
+}
+
+// renderAutoResponse replaces <OTRS_*> and <GOATFLOW_*> placeholders, also
+// HTML-encoded ones. Like OTRS, unknown placeholders become "-". Values are
+// escaped for HTML bodies.
+func renderAutoResponse(text string, vars map[string]string, isHTML bool) string {
+	return autoResponsePlaceholder.ReplaceAllStringFunc(text, func(tag string) string {
+		key := strings.TrimSuffix(strings.TrimSuffix(tag, ">"), "&gt;")
+		key = strings.TrimPrefix(strings.TrimPrefix(key, "<"), "&lt;")
+		key = key[strings.Index(key, "_")+1:]
+		value, ok := vars[key]
+		if !ok {
+			return "-"
+		}
+		if isHTML {
+			return html.EscapeString(value)
+		}
+		return value
+	})
+}
@@ -0,0 +1,128 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/testutil"
+)
+
+func TestAutoResponseService(t *testing.T) {
+	db := testutil.UseMigratedDB(t)
+	_, err := db.Exec(`INSERT INTO customer_user (login, email, customer_id, first_name, last_name, valid_id,
+		create_time, create_by, change_time, change_by)
+		VALUES ('jane', 'jane@example.com', 'acme', 'Jane', 'Doe', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`)
+	require.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO ticket (id, tn, title, queue_id, ticket_lock_id, user_id, responsible_user_id,
+		ticket_priority_id, ticket_state_id, customer_user_id, timeout, until_time, escalation_time, escalation_update_time,
+		escalation_response_time, escalation_solution_time, archive_flag, create_time, create_by, change_time, change_by)
+		VALUES (1, '2026031010000001', 'Printer <jammed>', 1, 1, 1, 1, 3, 1, 'jane', 0, 0, 0, 0, 0, 0, 0,
+		        CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	s := NewAutoResponseService(db)
+	mail := &stubMailQueue{}
+	s.mail = mail
+	clock := time.Date(2026, 3, 10, 9, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return clock }
+	s.loops.now = s.now
+
+	for name, ar := range map[string]*models.AutoResponse{
+		"no name":        {TypeID: 1, SystemAddressID: 1, Subject: "s", Body: "b"},
+		"no subject":     {Name: "x", TypeID: 1, SystemAddressID: 1, Body: "b"},
+		"unknown type":   {Name: "x", TypeID: 9, SystemAddressID: 1, Subject: "s", Body: "b"},
+		"unknown sender": {Name: "x", TypeID: 1, SystemAddressID: 99, Subject: "s", Body: "b"},
+		"content type":   {Name: "x", TypeID: 1, SystemAddressID: 1, Subject: "s", Body: "b", ContentType: "image/png"},
+	} {
+		assert.ErrorIs(t, s.Create(ctx, ar, 1), ErrAutoResponseInvalid, name)
+	}
+
+	reply := &models.AutoResponse{Name: " Thanks ", TypeID: models.AutoResponseTypeReply, SystemAddressID: 1,
+		Subject: "Re: <OTRS_CUSTOMER_SUBJECT>",
+		Body:    "Dear <OTRS_CUSTOMER_REALNAME>, ticket <OTRS_TICKET_TicketNumber> (<GOATFLOW_TICKET_Title>) is in <OTRS_TICKET_Queue>. <OTRS_UNKNOWN>"}
+	require.NoError(t, s.Create(ctx, reply, 1))
+	assert.Equal(t, "Thanks", reply.Name)
+	assert.Equal(t, "text/plain", reply.ContentType)
+	assert.Equal(t, 1, reply.ValidID)
+	assert.ErrorIs(t, s.Create(ctx, &models.AutoResponse{Name: "Thanks", TypeID: 1, SystemAddressID: 1, Subject: "s", Body: "b"}, 1),
+		ErrAutoResponseInvalid, "names are unique")
+	other := &models.AutoResponse{Name: "Thanks again", TypeID: models.AutoResponseTypeReply, SystemAddressID: 2,
+		Subject: "s", Body: "<p><OTRS_TICKET_Title></p>", ContentType: "TEXT/HTML"}
+	require.NoError(t, s.Create(ctx, other, 1))
+	assert.Equal(t, "text/html", other.ContentType)
+
+	_, err = s.SetQueueResponses(ctx, 99, []int{reply.ID}, 1)
+	assert.ErrorIs(t, err, ErrAutoResponseQueueNotFound)
+	_, err = s.SetQueueResponses(ctx, 1, []int{reply.ID, other.ID}, 1)
+	assert.ErrorIs(t, err, ErrAutoResponseInvalid, "one auto response per type and queue")
+	assigned, err := s.SetQueueResponses(ctx, 1, []int{reply.ID}, 1)
+	require.NoError(t, err)
+	require.Len(t, assigned, 1)
+
+	reply.TypeID = models.AutoResponseTypeFollowUp
+	assert.ErrorIs(t, s.Update(ctx, reply, 1), ErrAutoResponseInvalid, "assigned responses keep their type")
+	reply.TypeID = models.AutoResponseTypeReply
+	assert.ErrorIs(t, s.Update(ctx, &models.AutoResponse{ID: 999}, 1), ErrAutoResponseNotFound)
+
+	in := AutoResponseInput{TypeID: models.AutoResponseTypeReply, TicketID: 1, QueueID: 1,
+		To: "Jane Doe <jane@example.com>", Subject: "Printer", MessageID: "<abc@example.com>"}
+	sent, err := s.Respond(ctx, in)
+	require.NoError(t, err)
+	require.True(t, sent)
+	require.Len(t, mail.items, 1)
+	item := mail.items[0]
+	assert.Equal(t, "jane@example.com", item.Recipient)
+	assert.Equal(t, "postmaster@goatflow.local", *item.Sender)
+	raw := string(item.RawMessage)
+	assert.Contains(t, raw, "[Ticket#2026031010000001] Re: Printer")
+	assert.Contains(t, raw, "Dear Jane Doe, ticket 2026031010000001 (Printer <jammed>) is in Postmaster. -")
+	assert.Contains(t, raw, "Auto-Submitted: auto-replied")
+	assert.Contains(t, raw, "Precedence: bulk")
+	assert.Contains(t, raw, "X-GoatFlow-Loop: yes")
+	assert.Contains(t, raw, "In-Reply-To: <abc@example.com>")
+
+	for name, skip := range map[string]AutoResponseInput{
+		"automated":      {TypeID: 1, TicketID: 1, QueueID: 1, To: "jane@example.com", Automated: true},
+		"system address": {TypeID: 1, TicketID: 1, QueueID: 1, To: "Intake@goatflow.local"},
+		"bad address":    {TypeID: 1, TicketID: 1, QueueID: 1, To: "not an address"},
+		"other type":     {TypeID: models.AutoResponseTypeFollowUp, TicketID: 1, QueueID: 1, To: "jane@example.com"},
+		"other queue":    {TypeID: 1, TicketID: 1, QueueID: 2, To: "jane@example.com"},
+	} {
+		sent, err := s.Respond(ctx, skip)
+		require.NoError(t, err, name)
+		assert.False(t, sent, name)
+	}
+	assert.Len(t, mail.items, 1)
+
+	_, err = s.SetQueueResponses(ctx, 1, []int{other.ID}, 1)
+	require.NoError(t, err)
+	sent, err = s.Respond(ctx, in)
+	require.NoError(t, err)
+	require.True(t, sent)
+	assert.Contains(t, string(mail.items[1].RawMessage), "<p>Printer &lt;jammed&gt;</p>", "HTML bodies get escaped values")
+
+	_, err = s.loops.RecordBounce(ctx, models.EmailBounce{Email: "Jane@example.com", BounceType: models.BounceTypeHard})
+	require.NoError(t, err)
+	sent, err = s.Respond(ctx, in)
+	require.NoError(t, err)
+	assert.False(t, sent, "no auto responses to bouncing addresses")
+	require.NoError(t, s.loops.ClearBounce(ctx, "jane@example.com"))
+	sent, err = s.Respond(ctx, in)
+	require.NoError(t, err)
+	assert.True(t, sent)
+	count, err := s.loops.repo.CountSent(ctx, "jane@example.com", s.loops.day())
+	require.NoError(t, err)
+	assert.Equal(t, 3, count, "sent auto responses count towards the daily maximum")
+
+	require.NoError(t, s.Delete(ctx, other.ID))
+	assert.ErrorIs(t, s.Delete(ctx, other.ID), ErrAutoResponseNotFound)
+	queued, err := s.QueueResponses(ctx, 1)
+	require.NoError(t, err)
+	assert.Empty(t, queued)
+}
@@ -0,0 +1,151 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"path/filepath"
+	"time"
+
+	"github.com/goatkit/goatflow/internal/backup"
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/jobqueue"
+	"github.com/goatkit/goatflow/internal/version"
+)
+
+// BackupJobType is the job queue type that creates scheduled backups.
+const BackupJobType = "backup.create"
+
+// ErrBackupDir is returned when a backup has no directory to go to.
+var ErrBackupDir = errors.New("backup directory is not configured")
+
+// BackupJob is the payload of a BackupJobType job.
+type BackupJob struct {
+	Dir string `json:"dir"`
+	// FullEveryDays is how often a full backup is taken; the backups in
+	// between are incremental. 0 takes a full backup every time.
+	FullEveryDays int `json:"full_every_days"`
+	// RetentionDays removes archives older than this that no kept archive
+	// needs. 0 keeps all archives.
+	RetentionDays int `json:"retention_days"`
+}
+
+// BackupResult is the outcome of a backup run.
+type BackupResult struct {
+	Path     string
+	Manifest *backup.Manifest
+	Pruned   []string
+}
+
+// BackupService creates the scheduled backups of the installation in the
+// background.
+type BackupService struct {
+	db      *sql.DB
+	driver  string
+	sources backup.Sources
+	// enqueue starts a backup and returns the job ID; tests replace it.
+	enqueue func(ctx context.Context, job BackupJob) (string, error)
+	now     func() time.Time
+}
+
+// NewBackupService creates a backup service for the application's database
+// and directories. Backups go to the default job queue, or run in the
+// background of this process when the job queue is disabled.
+func NewBackupService(db *sql.DB) *BackupService {
+	s := &BackupService{
+		db:      db,
+		driver:  database.GetDBDriver(),
+		sources: backup.SourcesFromEnv(),
+		now:     time.Now,
+	}
+	s.enqueue = s.enqueueJob
+	return s
+}
+
+// Schedule queues a backup and returns the job ID, which is empty when the
+// backup runs in this process.
+func (s *BackupService) Schedule(ctx context.Context, job BackupJob) (string, error) {
+	if job.Dir == "" {
+		return "", ErrBackupDir
+	}
+	return s.enqueue(ctx, job)
+}
+
+func (s *BackupService) enqueueJob(ctx context.Context, job BackupJob) (string, error) {
+	queued, err := jobqueue.Default().Enqueue(ctx, BackupJobType, job, jobqueue.MaxAttempts(2))
+	if errors.Is(err, jobqueue.ErrNoQueue) {
+		go func() {
+			if _, err := s.Run(context.Background(), job); err != nil {
+				log.Printf("backup: failed: %v", err)
+			}
+		}()
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return queued.ID, nil
+}
+
+// HandleJob runs the backup of a BackupJobType job.
+func (s *BackupService) HandleJob(ctx context.Context, job *jobqueue.Job) error {
+	var payload BackupJob
+	if err := job.Decode(&payload); err != nil {
+		return jobqueue.Permanent(err)
+	}
+	_, err := s.Run(ctx, payload)
+	if errors.Is(err, ErrBackupDir) {
+		return jobqueue.Permanent(err)
+	}
+	return err
+}
+
+// Run creates a backup in job.Dir, incremental on top of the newest archive
+// unless the last full backup is FullEveryDays old, and then removes the
+// archives past their retention.
+func (s *BackupService) Run(ctx context.Context, job BackupJob) (*BackupResult, error) {
+	if job.Dir == "" {
+		return nil, ErrBackupDir
+	}
+	now := s.now()
+	var base string
+	if job.FullEveryDays > 0 {
+		full, err := backup.Latest(job.Dir, backup.KindFull)
+		if err != nil {
+			return nil, err
+		}
+		if full != nil && now.Sub(full.Manifest.CreatedAt) < time.Duration(job.FullEveryDays)*24*time.Hour {
+			latest, err := backup.Latest(job.Dir, "")
+			if err != nil {
+				return nil, err
+			}
+			base = latest.Path
+		}
+	}
+
+	path, m, err := backup.Create(ctx, s.db, backup.Options{
+		Dir:        job.Dir,
+		Driver:     s.driver,
+		Sources:    s.sources,
+		Base:       base,
+		AppVersion: version.Version,
+		Now:        s.now,
+	})
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("backup: created %s backup %s", m.Kind, filepath.Base(path))
+
+	result := &BackupResult{Path: path, Manifest: m}
+	if job.RetentionDays > 0 {
+		result.Pruned, err = backup.Prune(job.Dir, now.AddDate(0, 0, -job.RetentionDays))
+		if err != nil {
+			return result, err
+		}
+		if len(result.Pruned) > 0 {
+			log.Printf("backup: removed %d archive(s) past retention", len(result.Pruned))
+		}
+	}
+	return result, nil
+}
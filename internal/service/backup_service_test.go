@@ -0,0 +1,64 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/backup"
+	"github.com/goatkit/goatflow/internal/jobqueue"
+	"github.com/goatkit/goatflow/internal/testutil"
+)
+
+func TestBackupService(t *testing.T) {
+	db := testutil.UseMigratedDB(t)
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	svc := NewBackupService(db)
+	svc.sources = backup.Sources{Attachments: t.TempDir(), Plugins: t.TempDir()}
+	now := time.Date(2026, 10, 1, 1, 0, 0, 0, time.UTC)
+	svc.now = func() time.Time { return now }
+	job := BackupJob{Dir: dir, FullEveryDays: 7, RetentionDays: 5}
+
+	first, err := svc.Run(ctx, job)
+	require.NoError(t, err)
+	assert.Equal(t, backup.KindFull, first.Manifest.Kind)
+
+	now = now.AddDate(0, 0, 1)
+	second, err := svc.Run(ctx, job)
+	require.NoError(t, err)
+	assert.Equal(t, backup.KindIncremental, second.Manifest.Kind)
+	assert.Equal(t, filepath.Base(first.Path), second.Manifest.Base)
+	assert.Empty(t, second.Pruned, "the full backup is needed by the incremental one")
+
+	// A week after the full backup the next one is full again, and the
+	// old chain is past its retention
+	now = now.AddDate(0, 0, 7)
+	third, err := svc.Run(ctx, job)
+	require.NoError(t, err)
+	assert.Equal(t, backup.KindFull, third.Manifest.Kind)
+	assert.ElementsMatch(t, []string{filepath.Base(first.Path), filepath.Base(second.Path)}, third.Pruned)
+
+	var queued []BackupJob
+	svc.enqueue = func(ctx context.Context, job BackupJob) (string, error) {
+		queued = append(queued, job)
+		return "job-1", nil
+	}
+	id, err := svc.Schedule(ctx, job)
+	require.NoError(t, err)
+	assert.Equal(t, "job-1", id)
+	assert.Equal(t, []BackupJob{job}, queued)
+	_, err = svc.Schedule(ctx, BackupJob{})
+	assert.ErrorIs(t, err, ErrBackupDir)
+
+	payload, err := json.Marshal(BackupJob{})
+	require.NoError(t, err)
+	err = svc.HandleJob(ctx, &jobqueue.Job{Type: BackupJobType, Payload: payload})
+	assert.True(t, jobqueue.IsPermanent(err))
+}
@@ -0,0 +1,296 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/repository"
+	"github.com/goatkit/goatflow/internal/sysconfig"
+)
+
+// Errors returned by BrandingService.
+var (
+	ErrBrandingInvalid        = errors.New("invalid branding")
+	ErrBrandingTenantNotFound = errors.New("customer company not found")
+	ErrBrandingAssetNotFound  = errors.New("branding asset not found")
+)
+
+const (
+	// maxBrandingAssetSize limits uploaded logos and favicons.
+	maxBrandingAssetSize = 512 << 10
+
+	// brandingCacheTTL is how long rendered pages reuse a loaded branding.
+	brandingCacheTTL = 30 * time.Second
+)
+
+var (
+	brandingColor = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+	// brandingSVGActive matches scripts and event handlers in SVG images.
+	brandingSVGActive = regexp.MustCompile(`(?i)<script|<foreignobject|javascript:|\son[a-z]+\s*=`)
+
+	brandingAssetTypes = map[string]bool{
+		"image/png": true, "image/jpeg": true, "image/gif": true, "image/webp": true,
+		"image/x-icon": true, "image/svg+xml": true,
+	}
+)
+
+// Branding is the effective branding of a tenant as rendered into pages and
+// emails: its settings over the global ones, and the URLs of its images.
+type Branding struct {
+	sysconfig.BrandingConfig
+	Tenant     string `json:"tenant,omitempty"`
+	LogoURL    string `json:"logo_url,omitempty"`
+	FaviconURL string `json:"favicon_url,omitempty"`
+}
+
+type brandingCacheEntry struct {
+	branding *Branding
+	expires  time.Time
+}
+
+var brandingCache = struct {
+	sync.Mutex
+	entries map[string]brandingCacheEntry
+}{entries: map[string]brandingCacheEntry{}}
+
+// invalidateBrandingCache drops all cached brandings; tenants inherit the
+// global branding, so any change may affect every tenant.
+func invalidateBrandingCache() {
+	brandingCache.Lock()
+	brandingCache.entries = map[string]brandingCacheEntry{}
+	brandingCache.Unlock()
+}
+
+// BrandingService manages the branding: product name, colors, login page
+// text and email header and footer in sysconfig, and logo and favicon as
+// stored assets, globally or per customer company (tenant).
+type BrandingService struct {
+	db   *sql.DB
+	repo *repository.BrandingRepository
+	now  func() time.Time
+}
+
+// NewBrandingService creates a branding service.
+func NewBrandingService(db *sql.DB) *BrandingService {
+	return &BrandingService{db: db, repo: repository.NewBrandingRepository(db), now: time.Now}
+}
+
+// Branding returns the effective branding of a tenant, or the global
+// branding for an empty customerID. Results are cached briefly.
+func (s *BrandingService) Branding(ctx context.Context, customerID string) (*Branding, error) {
+	customerID = strings.TrimSpace(customerID)
+	brandingCache.Lock()
+	entry, ok := brandingCache.entries[customerID]
+	brandingCache.Unlock()
+	if ok && s.now().Before(entry.expires) {
+		return entry.branding, nil
+	}
+
+	cfg, err := sysconfig.LoadBrandingConfig(s.db, customerID)
+	if err != nil {
+		return nil, err
+	}
+	b := &Branding{BrandingConfig: cfg, Tenant: customerID}
+	scopes := []string{""}
+	if customerID != "" {
+		scopes = append(scopes, customerID)
+	}
+	assets, err := s.repo.ListAssets(ctx, scopes...)
+	if err != nil {
+		return nil, err
+	}
+	for _, a := range assets { // Tenant assets sort after global ones
+		switch a.Kind {
+		case models.BrandingAssetLogo:
+			b.LogoURL = brandingAssetURL(a)
+		case models.BrandingAssetFavicon:
+			b.FaviconURL = brandingAssetURL(a)
+		}
+	}
+
+	brandingCache.Lock()
+	brandingCache.entries[customerID] = brandingCacheEntry{branding: b, expires: s.now().Add(brandingCacheTTL)}
+	brandingCache.Unlock()
+	return b, nil
+}
+
+// brandingAssetURL returns the public URL of an asset; the checksum lets
+// browsers cache it until it changes.
+func brandingAssetURL(a *models.BrandingAsset) string {
+	q := url.Values{}
+	if a.Scope != "" {
+		q.Set("tenant", a.Scope)
+	}
+	q.Set("v", a.Checksum[:min(12, len(a.Checksum))])
+	return "/branding/" + a.Kind + "?" + q.Encode()
+}
+
+// Settings returns the branding settings stored for a scope: the global
+// ones for an empty customerID, else only the tenant's own.
+func (s *BrandingService) Settings(ctx context.Context, customerID string) (sysconfig.BrandingConfig, error) {
+	if err := s.checkTenant(ctx, customerID); err != nil {
+		return sysconfig.BrandingConfig{}, err
+	}
+	return sysconfig.LoadBrandingOverrides(s.db, strings.TrimSpace(customerID))
+}
+
+// SaveSettings validates and stores the branding settings of a scope.
+func (s *BrandingService) SaveSettings(ctx context.Context, customerID string, cfg sysconfig.BrandingConfig, userID int) (sysconfig.BrandingConfig, error) {
+	if err := s.checkTenant(ctx, customerID); err != nil {
+		return cfg, err
+	}
+	cfg.ProductName = strings.TrimSpace(cfg.ProductName)
+	cfg.PrimaryColor = strings.ToLower(strings.TrimSpace(cfg.PrimaryColor))
+	cfg.AccentColor = strings.ToLower(strings.TrimSpace(cfg.AccentColor))
+	cfg.LoginTitle = strings.TrimSpace(cfg.LoginTitle)
+	cfg.LoginText = strings.TrimSpace(cfg.LoginText)
+	cfg.EmailHeader = strings.TrimSpace(cfg.EmailHeader)
+	cfg.EmailFooter = strings.TrimSpace(cfg.EmailFooter)
+	switch {
+	case len(cfg.ProductName) > 100:
+		return cfg, fmt.Errorf("%w: product_name is longer than 100 characters", ErrBrandingInvalid)
+	case cfg.PrimaryColor != "" && !brandingColor.MatchString(cfg.PrimaryColor):
+		return cfg, fmt.Errorf("%w: primary_color must be a #rrggbb color", ErrBrandingInvalid)
+	case cfg.AccentColor != "" && !brandingColor.MatchString(cfg.AccentColor):
+		return cfg, fmt.Errorf("%w: accent_color must be a #rrggbb color", ErrBrandingInvalid)
+	case len(cfg.LoginTitle) > 200:
+		return cfg, fmt.Errorf("%w: login_title is longer than 200 characters", ErrBrandingInvalid)
+	case len(cfg.LoginText) > 2000:
+		return cfg, fmt.Errorf("%w: login_text is longer than 2000 characters", ErrBrandingInvalid)
+	case len(cfg.EmailHeader) > 10000 || len(cfg.EmailFooter) > 10000:
+		return cfg, fmt.Errorf("%w: email header and footer are limited to 10000 characters", ErrBrandingInvalid)
+	}
+	if err := sysconfig.SaveBrandingConfig(s.db, strings.TrimSpace(customerID), cfg, userID); err != nil {
+		return cfg, err
+	}
+	invalidateBrandingCache()
+	return cfg, nil
+}
+
+// Assets returns the assets stored for a scope, without their content.
+func (s *BrandingService) Assets(ctx context.Context, customerID string) ([]*models.BrandingAsset, error) {
+	if err := s.checkTenant(ctx, customerID); err != nil {
+		return nil, err
+	}
+	return s.repo.ListAssets(ctx, strings.TrimSpace(customerID))
+}
+
+// Asset returns a tenant's asset of a kind, falling back to the global one.
+func (s *BrandingService) Asset(ctx context.Context, customerID, kind string) (*models.BrandingAsset, error) {
+	customerID = strings.TrimSpace(customerID)
+	if customerID != "" {
+		a, err := s.repo.GetAsset(ctx, customerID, kind)
+		if err != nil || a != nil {
+			return a, err
+		}
+	}
+	a, err := s.repo.GetAsset(ctx, "", kind)
+	if err != nil {
+		return nil, err
+	}
+	if a == nil {
+		return nil, ErrBrandingAssetNotFound
+	}
+	return a, nil
+}
+
+// UploadAsset stores a logo or favicon for a scope, replacing the previous
+// one. PNG, JPEG, GIF, WebP, ICO and SVG images without scripts are
+// accepted.
+func (s *BrandingService) UploadAsset(ctx context.Context, customerID, kind, filename string, content []byte, userID int) (*models.BrandingAsset, error) {
+	if err := s.checkTenant(ctx, customerID); err != nil {
+		return nil, err
+	}
+	if kind != models.BrandingAssetLogo && kind != models.BrandingAssetFavicon {
+		return nil, fmt.Errorf("%w: unknown asset kind %q", ErrBrandingInvalid, kind)
+	}
+	if len(content) == 0 {
+		return nil, fmt.Errorf("%w: the file is empty", ErrBrandingInvalid)
+	}
+	if len(content) > maxBrandingAssetSize {
+		return nil, fmt.Errorf("%w: the file is larger than %d KiB", ErrBrandingInvalid, maxBrandingAssetSize>>10)
+	}
+	contentType, err := brandingContentType(filename, content)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(content)
+	a := &models.BrandingAsset{
+		Scope:       strings.TrimSpace(customerID),
+		Kind:        kind,
+		FileName:    truncateString(filepath.Base(strings.TrimSpace(filename)), 250),
+		ContentType: contentType,
+		Size:        len(content),
+		Checksum:    hex.EncodeToString(sum[:]),
+		Content:     content,
+		CreateTime:  s.now(),
+		CreateBy:    userID,
+	}
+	if err := s.repo.SaveAsset(ctx, a); err != nil {
+		return nil, err
+	}
+	invalidateBrandingCache()
+	return a, nil
+}
+
+// brandingContentType returns the image type of an upload.
+func brandingContentType(filename string, content []byte) (string, error) {
+	contentType := http.DetectContentType(content)
+	if strings.EqualFold(filepath.Ext(filename), ".svg") && bytes.Contains(bytes.ToLower(content), []byte("<svg")) {
+		if brandingSVGActive.Match(content) {
+			return "", fmt.Errorf("%w: SVG images must not contain scripts or event handlers", ErrBrandingInvalid)
+		}
+		contentType = "image/svg+xml"
+	}
+	if !brandingAssetTypes[contentType] {
+		return "", fmt.Errorf("%w: only PNG, JPEG, GIF, WebP, ICO and SVG images are allowed", ErrBrandingInvalid)
+	}
+	return contentType, nil
+}
+
+// DeleteAsset removes a scope's asset of a kind; tenants then show the
+// global one again.
+func (s *BrandingService) DeleteAsset(ctx context.Context, customerID, kind string) error {
+	if err := s.checkTenant(ctx, customerID); err != nil {
+		return err
+	}
+	deleted, err := s.repo.DeleteAsset(ctx, strings.TrimSpace(customerID), kind)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrBrandingAssetNotFound
+	}
+	invalidateBrandingCache()
+	return nil
+}
+
+// checkTenant verifies that a non-empty customerID names a customer company.
+func (s *BrandingService) checkTenant(ctx context.Context, customerID string) error {
+	customerID = strings.TrimSpace(customerID)
+	if customerID == "" {
+		return nil
+	}
+	exists, err := s.repo.TenantExists(ctx, customerID)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return ErrBrandingTenantNotFound
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/sysconfig"
+	"github.com/goatkit/goatflow/internal/testutil"
+)
+
+var testPNG = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR\x00\x00\x00\x01\x00\x00\x00\x01\x08\x06\x00\x00\x00\x1f\x15\xc4\x89")
+
+func TestBrandingService(t *testing.T) {
+	db := testutil.UseMigratedDB(t)
+	ctx := context.Background()
+	_, err := db.Exec(`INSERT INTO customer_company (customer_id, name, valid_id, create_time, create_by, change_time, change_by)
+		VALUES ('acme', 'Acme', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`)
+	require.NoError(t, err)
+	invalidateBrandingCache()
+	t.Cleanup(invalidateBrandingCache)
+	s := NewBrandingService(db)
+
+	b, err := s.Branding(ctx, "")
+	require.NoError(t, err)
+	assert.Equal(t, "GoatFlow", b.ProductName)
+	assert.Empty(t, b.LogoURL)
+
+	t.Run("settings", func(t *testing.T) {
+		_, err := s.SaveSettings(ctx, "", sysconfig.BrandingConfig{PrimaryColor: "blue"}, 1)
+		assert.ErrorIs(t, err, ErrBrandingInvalid)
+		_, err = s.SaveSettings(ctx, "", sysconfig.BrandingConfig{LoginText: strings.Repeat("x", 2001)}, 1)
+		assert.ErrorIs(t, err, ErrBrandingInvalid)
+		_, err = s.SaveSettings(ctx, "nobody", sysconfig.BrandingConfig{}, 1)
+		assert.ErrorIs(t, err, ErrBrandingTenantNotFound)
+
+		cfg, err := s.SaveSettings(ctx, "", sysconfig.BrandingConfig{ProductName: " Helpdesk ", PrimaryColor: "#AA0000",
+			EmailFooter: "Helpdesk Inc."}, 1)
+		require.NoError(t, err)
+		assert.Equal(t, "#aa0000", cfg.PrimaryColor)
+		_, err = s.SaveSettings(ctx, "acme", sysconfig.BrandingConfig{ProductName: "Acme Support", AccentColor: "#00aa00"}, 1)
+		require.NoError(t, err)
+
+		global, err := s.Branding(ctx, "")
+		require.NoError(t, err)
+		assert.Equal(t, "Helpdesk", global.ProductName)
+		assert.Empty(t, global.AccentColor)
+
+		tenant, err := s.Branding(ctx, "acme")
+		require.NoError(t, err)
+		assert.Equal(t, "Acme Support", tenant.ProductName)
+		assert.Equal(t, "#aa0000", tenant.PrimaryColor, "unset tenant values inherit the global ones")
+		assert.Equal(t, "#00aa00", tenant.AccentColor)
+		assert.Equal(t, "Helpdesk Inc.", tenant.EmailFooter)
+
+		own, err := s.Settings(ctx, "acme")
+		require.NoError(t, err)
+		assert.Equal(t, sysconfig.BrandingConfig{ProductName: "Acme Support", AccentColor: "#00aa00"}, own)
+	})
+
+	t.Run("assets", func(t *testing.T) {
+		_, err := s.UploadAsset(ctx, "", "banner", "logo.png", testPNG, 1)
+		assert.ErrorIs(t, err, ErrBrandingInvalid)
+		_, err = s.UploadAsset(ctx, "", models.BrandingAssetLogo, "logo.txt", []byte("not an image"), 1)
+		assert.ErrorIs(t, err, ErrBrandingInvalid)
+		_, err = s.UploadAsset(ctx, "", models.BrandingAssetLogo, "logo.svg",
+			[]byte(`<svg xmlns="http://www.w3.org/2000/svg" onload="alert(1)"></svg>`), 1)
+		assert.ErrorIs(t, err, ErrBrandingInvalid)
+		_, err = s.UploadAsset(ctx, "", models.BrandingAssetLogo, "logo.png", make([]byte, maxBrandingAssetSize+1), 1)
+		assert.ErrorIs(t, err, ErrBrandingInvalid)
+
+		logo, err := s.UploadAsset(ctx, "", models.BrandingAssetLogo, "logo.png", testPNG, 1)
+		require.NoError(t, err)
+		assert.Equal(t, "image/png", logo.ContentType)
+		icon, err := s.UploadAsset(ctx, "acme", models.BrandingAssetFavicon, "favicon.svg",
+			[]byte(`<svg xmlns="http://www.w3.org/2000/svg"><circle r="4"/></svg>`), 1)
+		require.NoError(t, err)
+		assert.Equal(t, "image/svg+xml", icon.ContentType)
+
+		tenant, err := s.Branding(ctx, "acme")
+		require.NoError(t, err)
+		assert.Equal(t, "/branding/logo?v="+logo.Checksum[:12], tenant.LogoURL, "tenants show the global logo")
+		assert.Equal(t, "/branding/favicon?tenant=acme&v="+icon.Checksum[:12], tenant.FaviconURL)
+
+		a, err := s.Asset(ctx, "acme", models.BrandingAssetLogo)
+		require.NoError(t, err)
+		assert.Equal(t, testPNG, a.Content)
+		_, err = s.Asset(ctx, "", models.BrandingAssetFavicon)
+		assert.ErrorIs(t, err, ErrBrandingAssetNotFound)
+
+		require.NoError(t, s.DeleteAsset(ctx, "acme", models.BrandingAssetFavicon))
+		assert.ErrorIs(t, s.DeleteAsset(ctx, "acme", models.BrandingAssetFavicon), ErrBrandingAssetNotFound)
+		tenant, err = s.Branding(ctx, "acme")
+		require.NoError(t, err)
+		assert.Empty(t, tenant.FaviconURL)
+	})
+}
@@ -0,0 +1,373 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/repository"
+	"github.com/goatkit/goatflow/internal/services/acl"
+)
+
+// ErrBulkChangeInvalidTarget is returned by Plan when a target state, queue,
+// priority or agent does not exist or is not valid.
+var ErrBulkChangeInvalidTarget = errors.New("invalid bulk change target")
+
+// Outcomes of a BulkChangePrediction.
+const (
+	BulkChangeApplies  = "change"
+	BulkChangeNoChange = "no_change"
+	BulkChangeBlocked  = "blocked"
+)
+
+// BulkChange is a set of field changes applied to many tickets by a bulk
+// action or Generic Agent job. Nil fields are left unchanged.
+type BulkChange struct {
+	StateID       *int
+	QueueID       *int
+	PriorityID    *int
+	OwnerID       *int
+	ResponsibleID *int
+}
+
+// BulkFieldChange is one field a ticket would change.
+type BulkFieldChange struct {
+	Field string `json:"field"`
+	From  int    `json:"from"`
+	To    int    `json:"to"`
+	// ToName is the state, queue or priority name of To, when known.
+	ToName string `json:"to_name,omitempty"`
+}
+
+// BulkChangePrediction is the predicted outcome of a change for one ticket.
+type BulkChangePrediction struct {
+	TicketID     int               `json:"ticket_id"`
+	TicketNumber string            `json:"ticket_number,omitempty"`
+	Outcome      string            `json:"outcome"`
+	Changes      []BulkFieldChange `json:"changes,omitempty"`
+	// Reasons explain why a blocked ticket would not change.
+	Reasons []string `json:"reasons,omitempty"`
+	// Warnings do not stop the change but may surprise whoever runs it.
+	Warnings []string `json:"warnings,omitempty"`
+	// OwnerID and ResponsibleID are the agents that would actually be set,
+	// after out-of-office substitution; 0 when unchanged.
+	OwnerID       int `json:"-"`
+	ResponsibleID int `json:"-"`
+}
+
+// BulkChangePreview summarises the predictions for a set of tickets.
+type BulkChangePreview struct {
+	Total      int                    `json:"total"`
+	WillChange int                    `json:"will_change"`
+	NoChange   int                    `json:"no_change"`
+	Blocked    int                    `json:"blocked"`
+	Tickets    []BulkChangePrediction `json:"tickets"`
+}
+
+// BulkChangeService predicts and checks ticket changes made in bulk, so the
+// dry run of a bulk action or Generic Agent job reports the same permission,
+// ACL and state blocks the real run enforces.
+type BulkChangeService struct {
+	db          *sql.DB
+	outOfOffice *OutOfOfficeService
+	locks       *TicketLockService
+	// isAdmin and hasQueueAccess check the acting agent's permissions.
+	isAdmin        func(ctx context.Context, userID int) (bool, error)
+	hasQueueAccess func(ctx context.Context, userID, queueID int, perm string) (bool, error)
+	// filterOptions applies ACLs to the options of a ticket field.
+	filterOptions func(ctx context.Context, aclCtx *models.ACLContext, subType string, options map[int]string) (map[int]string, error)
+	// approvalRule returns the rule that makes the agent ask for approval.
+	approvalRule func(ctx context.Context, queueID int, action models.ApprovalAction, userID int) (*models.ApprovalRule, error)
+	// typeWorkflow returns the workflow of a ticket type.
+	typeWorkflow func(ctx context.Context, typeID int) (*models.TicketTypeWorkflow, error)
+}
+
+// NewBulkChangeService creates a bulk change service.
+func NewBulkChangeService(db *sql.DB) *BulkChangeService {
+	access := NewQueueAccessService(db)
+	acls := acl.NewService(db)
+	approvals := NewApprovalService(db)
+	return &BulkChangeService{
+		db:          db,
+		outOfOffice: NewOutOfOfficeService(db),
+		locks:       NewTicketLockService(db, 0),
+		isAdmin: func(ctx context.Context, userID int) (bool, error) {
+			return access.IsAdmin(ctx, uint(userID))
+		},
+		hasQueueAccess: func(ctx context.Context, userID, queueID int, perm string) (bool, error) {
+			return access.HasQueueAccess(ctx, uint(userID), uint(queueID), perm)
+		},
+		filterOptions: func(ctx context.Context, aclCtx *models.ACLContext, subType string, options map[int]string) (map[int]string, error) {
+			return acls.FilterOptions(ctx, aclCtx, "Ticket", subType, options)
+		},
+		approvalRule: approvals.RuleFor,
+		typeWorkflow: repository.NewTicketTypeRepository(db).GetWorkflow,
+	}
+}
+
+// BulkChangePlan is a validated BulkChange for one acting agent.
+type BulkChangePlan struct {
+	svc     *BulkChangeService
+	userID  int
+	admin   bool
+	change  BulkChange
+	names   map[string]string // "State"/"Queue"/"Priority" -> target name
+	merging bool              // target state is of type merged
+	closing bool              // target state is of type closed
+}
+
+// Plan validates the change targets and the acting agent. userID is the
+// agent the change is made as; Generic Agent jobs run as user 1.
+func (s *BulkChangeService) Plan(ctx context.Context, userID int, change BulkChange) (*BulkChangePlan, error) {
+	p := &BulkChangePlan{svc: s, userID: userID, change: change, names: map[string]string{}}
+
+	if change.StateID != nil {
+		var name, typeName string
+		err := s.db.QueryRowContext(ctx, database.ConvertPlaceholders(`
+			SELECT s.name, st.name
+			FROM ticket_state s
+			JOIN ticket_state_type st ON st.id = s.type_id
+			WHERE s.id = ? AND s.valid_id = 1`), *change.StateID).Scan(&name, &typeName)
+		if err != nil {
+			return nil, s.targetError(err, "state", *change.StateID)
+		}
+		p.names["State"] = name
+		p.merging = typeName == "merged"
+		p.closing = typeName == "closed"
+	}
+	for _, target := range []struct {
+		field, table string
+		id           *int
+	}{
+		{"Queue", "queue", change.QueueID},
+		{"Priority", "ticket_priority", change.PriorityID},
+	} {
+		if target.id == nil {
+			continue
+		}
+		var name string
+		err := s.db.QueryRowContext(ctx, database.ConvertPlaceholders(
+			`SELECT name FROM `+target.table+` WHERE id = ? AND valid_id = 1`), *target.id).Scan(&name)
+		if err != nil {
+			return nil, s.targetError(err, target.table, *target.id)
+		}
+		p.names[target.field] = name
+	}
+	for _, id := range []*int{change.OwnerID, change.ResponsibleID} {
+		if id != nil && !s.outOfOffice.validAgent(ctx, *id) {
+			return nil, fmt.Errorf("%w: agent %d", ErrBulkChangeInvalidTarget, *id)
+		}
+	}
+
+	admin, err := s.isAdmin(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("check admin: %w", err)
+	}
+	p.admin = admin
+	return p, nil
+}
+
+func (s *BulkChangeService) targetError(err error, what string, id int) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w: %s %d", ErrBulkChangeInvalidTarget, what, id)
+	}
+	return fmt.Errorf("load %s %d: %w", what, id, err)
+}
+
+// bulkTicket is the ticket state a prediction starts from.
+type bulkTicket struct {
+	id, queueID, stateID, priorityID, ownerID, responsibleID int
+	typeID, serviceID, slaID, lockID                         int
+	tn, stateType, customerID, customerUserID                string
+}
+
+func (p *BulkChangePlan) load(ctx context.Context, ticketID int) (*bulkTicket, error) {
+	t := bulkTicket{id: ticketID}
+	err := p.svc.db.QueryRowContext(ctx, database.ConvertPlaceholders(`
+		SELECT t.tn, t.queue_id, t.ticket_state_id, t.ticket_priority_id, COALESCE(t.user_id, 0),
+			COALESCE(t.responsible_user_id, 0), COALESCE(t.type_id, 0), COALESCE(t.service_id, 0),
+			COALESCE(t.sla_id, 0), t.ticket_lock_id, COALESCE(t.customer_id, ''), COALESCE(t.customer_user_id, ''),
+			st.name
+		FROM ticket t
+		JOIN ticket_state s ON s.id = t.ticket_state_id
+		JOIN ticket_state_type st ON st.id = s.type_id
+		WHERE t.id = ?`), ticketID).Scan(&t.tn, &t.queueID, &t.stateID, &t.priorityID, &t.ownerID,
+		&t.responsibleID, &t.typeID, &t.serviceID, &t.slaID, &t.lockID, &t.customerID, &t.customerUserID,
+		&t.stateType)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("load ticket %d: %w", ticketID, err)
+	}
+	return &t, nil
+}
+
+// Check predicts the outcome of the change for one ticket. Errors reading
+// the ticket are returned; everything that would stop the change is
+// reported as a blocked outcome instead.
+func (p *BulkChangePlan) Check(ctx context.Context, ticketID int) (BulkChangePrediction, error) {
+	pred := BulkChangePrediction{TicketID: ticketID}
+	t, err := p.load(ctx, ticketID)
+	if err != nil {
+		return pred, err
+	}
+	if t == nil {
+		pred.Outcome = BulkChangeBlocked
+		pred.Reasons = []string{"ticket not found"}
+		return pred, nil
+	}
+	pred.TicketNumber = t.tn
+	block := func(format string, args ...any) {
+		pred.Reasons = append(pred.Reasons, fmt.Sprintf(format, args...))
+	}
+
+	if t.stateType == "merged" {
+		block("ticket is merged into another ticket")
+	}
+	if p.merging {
+		block("tickets can only be set to a merged state by merging them")
+	}
+
+	if !p.admin {
+		if ok, err := p.svc.hasQueueAccess(ctx, p.userID, t.queueID, "rw"); err != nil {
+			return pred, err
+		} else if !ok {
+			block("no rw permission on the ticket's queue")
+		}
+		if p.change.QueueID != nil && *p.change.QueueID != t.queueID {
+			if ok, err := p.svc.hasQueueAccess(ctx, p.userID, *p.change.QueueID, "move_into"); err != nil {
+				return pred, err
+			} else if !ok {
+				block("no move_into permission on queue %q", p.names["Queue"])
+			}
+		}
+	}
+
+	aclCtx := &models.ACLContext{
+		UserID: p.userID, TicketID: t.id, QueueID: t.queueID, StateID: t.stateID, PriorityID: t.priorityID,
+		TypeID: t.typeID, ServiceID: t.serviceID, SLAID: t.slaID, OwnerID: t.ownerID, LockID: t.lockID,
+		CustomerID: t.customerID, CustomerUserID: t.customerUserID, Action: "AgentTicketBulk",
+	}
+	for _, f := range []struct {
+		field   string
+		target  *int
+		current int
+	}{
+		{"State", p.change.StateID, t.stateID},
+		{"Queue", p.change.QueueID, t.queueID},
+		{"Priority", p.change.PriorityID, t.priorityID},
+	} {
+		if f.target == nil || *f.target == f.current {
+			continue
+		}
+		allowed, err := p.svc.filterOptions(ctx, aclCtx, f.field, map[int]string{*f.target: p.names[f.field]})
+		if err != nil {
+			return pred, err
+		}
+		if _, ok := allowed[*f.target]; !ok {
+			block("ACL does not allow %s %q for this ticket", f.field, p.names[f.field])
+		}
+		pred.Changes = append(pred.Changes, BulkFieldChange{Field: f.field, From: f.current, To: *f.target, ToName: p.names[f.field]})
+	}
+
+	for _, a := range []struct {
+		action  models.ApprovalAction
+		applies bool
+		what    string
+	}{
+		{models.ApprovalActionClose, p.closing && *p.change.StateID != t.stateID, "closing"},
+		{models.ApprovalActionMove, p.change.QueueID != nil && *p.change.QueueID != t.queueID, "moving"},
+	} {
+		if !a.applies {
+			continue
+		}
+		rule, err := p.svc.approvalRule(ctx, t.queueID, a.action, p.userID)
+		if err != nil {
+			return pred, err
+		}
+		if rule != nil {
+			block("%s tickets in this queue needs approval (rule %q)", a.what, rule.Name)
+		}
+	}
+
+	if p.change.StateID != nil && *p.change.StateID != t.stateID && t.typeID > 0 {
+		wf, err := p.svc.typeWorkflow(ctx, t.typeID)
+		if err != nil {
+			return pred, err
+		}
+		if !wf.AllowsTransition(t.stateID, *p.change.StateID) {
+			block("the ticket type does not allow changing the state to %q", p.names["State"])
+		}
+	}
+
+	for _, f := range []struct {
+		field   string
+		target  *int
+		current int
+		set     *int
+	}{
+		{"Owner", p.change.OwnerID, t.ownerID, &pred.OwnerID},
+		{"Responsible", p.change.ResponsibleID, t.responsibleID, &pred.ResponsibleID},
+	} {
+		if f.target == nil {
+			continue
+		}
+		agentID, err := p.svc.outOfOffice.ResolveAgent(ctx, *f.target)
+		if errors.Is(err, ErrAgentOutOfOffice) {
+			block("%s %d is out of office with no available substitute", f.field, *f.target)
+			continue
+		}
+		if err != nil {
+			return pred, err
+		}
+		if agentID != *f.target {
+			pred.Warnings = append(pred.Warnings, fmt.Sprintf("%s %d is out of office; agent %d is assigned instead", f.field, *f.target, agentID))
+		}
+		*f.set = agentID
+		if agentID != f.current {
+			pred.Changes = append(pred.Changes, BulkFieldChange{Field: f.field, From: f.current, To: agentID})
+		}
+	}
+
+	if lock, err := p.svc.locks.Get(ctx, t.id); err == nil && lock.Locked && lock.OwnerID != p.userID {
+		pred.Warnings = append(pred.Warnings, fmt.Sprintf("locked by agent %d", lock.OwnerID))
+	}
+
+	switch {
+	case len(pred.Reasons) > 0:
+		pred.Outcome = BulkChangeBlocked
+	case len(pred.Changes) == 0:
+		pred.Outcome = BulkChangeNoChange
+	default:
+		pred.Outcome = BulkChangeApplies
+	}
+	return pred, nil
+}
+
+// Preview predicts the change for every ticket without changing any.
+func (p *BulkChangePlan) Preview(ctx context.Context, ticketIDs []int) (*BulkChangePreview, error) {
+	preview := &BulkChangePreview{Total: len(ticketIDs), Tickets: make([]BulkChangePrediction, 0, len(ticketIDs))}
+	for _, id := range ticketIDs {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		pred, err := p.Check(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		switch pred.Outcome {
+		case BulkChangeApplies:
+			preview.WillChange++
+		case BulkChangeNoChange:
+			preview.NoChange++
+		case BulkChangeBlocked:
+			preview.Blocked++
+		}
+		preview.Tickets = append(preview.Tickets, pred)
+	}
+	return preview, nil
+}
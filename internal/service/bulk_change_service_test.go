@@ -0,0 +1,223 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/testutil"
+)
+
+func newBulkChangeTestService(t *testing.T) (*BulkChangeService, *sql.DB) {
+	t.Helper()
+	db := testutil.MigratedDB(t)
+	for _, id := range []int{1, 5, 6, 7} {
+		_, err := db.Exec(`INSERT INTO users (id, login, pw, first_name, last_name, valid_id, create_time, create_by, change_time, change_by)
+			VALUES (?, ?, 'x', 'Agent', ?, 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`, id, fmt.Sprintf("agent%d", id), fmt.Sprint(id))
+		require.NoError(t, err)
+	}
+	for _, stmt := range []string{
+		`UPDATE queue SET name = 'Restricted' WHERE id = 3`,
+		`UPDATE queue SET valid_id = 2 WHERE id = 4`,
+		`INSERT INTO ticket_state_type (id, name, create_time, create_by, change_time, change_by)
+			VALUES (7, 'merged', CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+		`INSERT INTO ticket_state (id, name, type_id, valid_id, create_time, create_by, change_time, change_by)
+			VALUES (9, 'merged', 7, 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+	} {
+		_, err := db.Exec(stmt)
+		require.NoError(t, err, stmt)
+	}
+	// Ticket 1 is open in queue 1, 2 closed in queue 2, 3 open in queue 3
+	// and 4 merged in queue 1.
+	for _, tk := range []struct{ id, queueID, stateID int }{{1, 1, 2}, {2, 2, 4}, {3, 3, 2}, {4, 1, 9}} {
+		_, err := db.Exec(`INSERT INTO ticket (id, tn, title, queue_id, ticket_lock_id, user_id, responsible_user_id,
+			ticket_priority_id, ticket_state_id, timeout, until_time, escalation_time, escalation_update_time,
+			escalation_response_time, escalation_solution_time, archive_flag, create_time, create_by, change_time, change_by)
+			VALUES (?, ?, 'Printer', ?, 1, 5, 5, 3, ?, 0, 0, 0, 0, 0, 0, 0, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+			tk.id, fmt.Sprint(1000+tk.id), tk.queueID, tk.stateID)
+		require.NoError(t, err)
+	}
+
+	svc := NewBulkChangeService(db)
+	svc.isAdmin = func(_ context.Context, userID int) (bool, error) { return userID == 1, nil }
+	svc.hasQueueAccess = func(_ context.Context, _, queueID int, perm string) (bool, error) {
+		// Agents may work in queues 1 and 2 but only move tickets into queue 2.
+		if perm == "move_into" {
+			return queueID == 2, nil
+		}
+		return queueID != 3, nil
+	}
+	svc.filterOptions = func(_ context.Context, aclCtx *models.ACLContext, subType string, options map[int]string) (map[int]string, error) {
+		// An ACL stops tickets in queue 2 from being closed.
+		if subType == "State" && aclCtx.QueueID == 2 {
+			delete(options, 4)
+		}
+		return options, nil
+	}
+	svc.approvalRule = func(_ context.Context, queueID int, action models.ApprovalAction, _ int) (*models.ApprovalRule, error) {
+		// Closing tickets in queue 3 needs approval.
+		if queueID == 3 && action == models.ApprovalActionClose {
+			return &models.ApprovalRule{ID: 1, Name: "Restricted closes"}, nil
+		}
+		return nil, nil
+	}
+	svc.typeWorkflow = func(_ context.Context, typeID int) (*models.TicketTypeWorkflow, error) {
+		// Type 2 tickets may only move from open to closed successful.
+		wf := &models.TicketTypeWorkflow{TypeID: typeID}
+		if typeID == 2 {
+			wf.Transitions = []models.TicketTypeTransition{{FromStateID: 2, ToStateID: 4}}
+		}
+		return wf, nil
+	}
+	svc.outOfOffice.now = func() time.Time { return time.Date(2025, 3, 10, 12, 0, 0, 0, time.Local) }
+	return svc, db
+}
+
+func intRef(v int) *int { return &v }
+
+func TestBulkChangePlan_Preview(t *testing.T) {
+	svc, _ := newBulkChangeTestService(t)
+	ctx := context.Background()
+
+	plan, err := svc.Plan(ctx, 6, BulkChange{StateID: intRef(4)})
+	require.NoError(t, err)
+	preview, err := plan.Preview(ctx, []int{1, 2, 3, 4, 99})
+	require.NoError(t, err)
+
+	assert.Equal(t, 5, preview.Total)
+	assert.Equal(t, 1, preview.WillChange)
+	assert.Equal(t, 1, preview.NoChange)
+	assert.Equal(t, 3, preview.Blocked)
+
+	byID := map[int]BulkChangePrediction{}
+	for _, p := range preview.Tickets {
+		byID[p.TicketID] = p
+	}
+	assert.Equal(t, BulkChangeApplies, byID[1].Outcome)
+	assert.Equal(t, []BulkFieldChange{{Field: "State", From: 2, To: 4, ToName: "closed successful"}}, byID[1].Changes)
+	assert.Equal(t, BulkChangeNoChange, byID[2].Outcome, "already closed, so the ACL is not consulted")
+	assert.Equal(t, BulkChangeBlocked, byID[3].Outcome)
+	assert.Contains(t, byID[3].Reasons, "no rw permission on the ticket's queue")
+	assert.Contains(t, byID[4].Reasons, "ticket is merged into another ticket")
+	assert.Equal(t, []string{"ticket not found"}, byID[99].Reasons)
+}
+
+func TestBulkChangePlan_ACLAndMoveInto(t *testing.T) {
+	svc, db := newBulkChangeTestService(t)
+	ctx := context.Background()
+
+	_, err := db.Exec(`UPDATE ticket SET ticket_state_id = 2 WHERE id = 2`)
+	require.NoError(t, err)
+	plan, err := svc.Plan(ctx, 6, BulkChange{StateID: intRef(4)})
+	require.NoError(t, err)
+	pred, err := plan.Check(ctx, 2)
+	require.NoError(t, err)
+	assert.Equal(t, BulkChangeBlocked, pred.Outcome)
+	assert.Equal(t, []string{`ACL does not allow State "closed successful" for this ticket`}, pred.Reasons)
+
+	plan, err = svc.Plan(ctx, 6, BulkChange{QueueID: intRef(3)})
+	require.NoError(t, err)
+	pred, err = plan.Check(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []string{`no move_into permission on queue "Restricted"`}, pred.Reasons)
+
+	// Admins skip queue permissions.
+	plan, err = svc.Plan(ctx, 1, BulkChange{QueueID: intRef(3)})
+	require.NoError(t, err)
+	pred, err = plan.Check(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, BulkChangeApplies, pred.Outcome)
+}
+
+func TestBulkChangePlan_OutOfOfficeAndLocks(t *testing.T) {
+	svc, db := newBulkChangeTestService(t)
+	ctx := context.Background()
+
+	_, err := svc.outOfOffice.Set(ctx, OutOfOffice{UserID: 6, Enabled: true, Start: "2025-03-01", End: "2025-03-31", SubstituteID: 7})
+	require.NoError(t, err)
+	_, err = svc.outOfOffice.Set(ctx, OutOfOffice{UserID: 7, Enabled: true, Start: "2025-03-10", End: "2025-03-10"})
+	require.NoError(t, err)
+
+	plan, err := svc.Plan(ctx, 1, BulkChange{OwnerID: intRef(6)})
+	require.NoError(t, err)
+	pred, err := plan.Check(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, BulkChangeBlocked, pred.Outcome)
+	assert.Equal(t, []string{"Owner 6 is out of office with no available substitute"}, pred.Reasons)
+
+	_, err = svc.outOfOffice.Set(ctx, OutOfOffice{UserID: 7})
+	require.NoError(t, err)
+	_, err = db.Exec(`UPDATE ticket SET ticket_lock_id = 2, user_id = 5, timeout = ? WHERE id = 1`, time.Now().Unix())
+	require.NoError(t, err)
+	pred, err = plan.Check(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, BulkChangeApplies, pred.Outcome)
+	assert.Equal(t, 7, pred.OwnerID)
+	assert.Equal(t, []string{"Owner 6 is out of office; agent 7 is assigned instead", "locked by agent 5"}, pred.Warnings)
+}
+
+func TestBulkChangePlan_NeedsApproval(t *testing.T) {
+	svc, _ := newBulkChangeTestService(t)
+	ctx := context.Background()
+
+	plan, err := svc.Plan(ctx, 1, BulkChange{StateID: intRef(4)})
+	require.NoError(t, err)
+	pred, err := plan.Check(ctx, 3)
+	require.NoError(t, err)
+	assert.Equal(t, BulkChangeBlocked, pred.Outcome)
+	assert.Equal(t, []string{`closing tickets in this queue needs approval (rule "Restricted closes")`}, pred.Reasons)
+
+	// Other states and queues are not guarded.
+	plan, err = svc.Plan(ctx, 1, BulkChange{QueueID: intRef(2)})
+	require.NoError(t, err)
+	pred, err = plan.Check(ctx, 3)
+	require.NoError(t, err)
+	assert.Equal(t, BulkChangeApplies, pred.Outcome)
+}
+
+func TestBulkChangePlan_TicketTypeTransitions(t *testing.T) {
+	svc, db := newBulkChangeTestService(t)
+	ctx := context.Background()
+	_, err := db.Exec(`UPDATE ticket SET type_id = 2 WHERE id IN (1, 2)`)
+	require.NoError(t, err)
+
+	plan, err := svc.Plan(ctx, 1, BulkChange{StateID: intRef(2)})
+	require.NoError(t, err)
+	pred, err := plan.Check(ctx, 2)
+	require.NoError(t, err)
+	assert.Equal(t, BulkChangeBlocked, pred.Outcome)
+	assert.Equal(t, []string{`the ticket type does not allow changing the state to "open"`}, pred.Reasons)
+
+	plan, err = svc.Plan(ctx, 1, BulkChange{StateID: intRef(4)})
+	require.NoError(t, err)
+	pred, err = plan.Check(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, BulkChangeApplies, pred.Outcome)
+}
+
+func TestBulkChangeService_PlanRejectsInvalidTargets(t *testing.T) {
+	svc, _ := newBulkChangeTestService(t)
+	ctx := context.Background()
+
+	for _, change := range []BulkChange{
+		{StateID: intRef(404)},
+		{QueueID: intRef(4)},
+		{PriorityID: intRef(404)},
+		{OwnerID: intRef(404)},
+	} {
+		_, err := svc.Plan(ctx, 6, change)
+		assert.ErrorIs(t, err, ErrBulkChangeInvalidTarget)
+	}
+
+	plan, err := svc.Plan(ctx, 1, BulkChange{StateID: intRef(9)})
+	require.NoError(t, err)
+	pred, err := plan.Check(ctx, 1)
+	require.NoError(t, err)
+	assert.Contains(t, pred.Reasons, "tickets can only be set to a merged state by merging them")
+}
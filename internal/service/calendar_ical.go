@@ -0,0 +1,106 @@
+package service
+
+import (
+	"strings"
+	"time"
+
+	"github.com/goatkit/goatflow/internal/models"
+)
+
+const (
+	icalDateTime = "20060102T150405Z"
+	icalDate     = "20060102"
+	// icalLineLimit is the maximum line length in octets (RFC 5545 3.1).
+	icalLineLimit = 75
+)
+
+// RenderICalendar renders appointments as an iCalendar (RFC 5545) object
+// named after the calendar. Linked tickets are listed in the descriptions.
+func RenderICalendar(calendarName string, appointments []*models.CalendarAppointment) []byte {
+	var b strings.Builder
+	icalLine(&b, "BEGIN:VCALENDAR")
+	icalLine(&b, "VERSION:2.0")
+	icalLine(&b, "PRODID:-//GoatFlow//Calendar//EN")
+	icalLine(&b, "CALSCALE:GREGORIAN")
+	if calendarName != "" {
+		icalLine(&b, "X-WR-CALNAME:"+icalText(calendarName))
+	}
+	for _, a := range appointments {
+		icalLine(&b, "BEGIN:VEVENT")
+		icalLine(&b, "UID:"+a.UniqueID)
+		icalLine(&b, "DTSTAMP:"+a.ChangeTime.UTC().Format(icalDateTime))
+		icalLine(&b, "CREATED:"+a.CreateTime.UTC().Format(icalDateTime))
+		icalLine(&b, "LAST-MODIFIED:"+a.ChangeTime.UTC().Format(icalDateTime))
+		if a.AllDay {
+			icalLine(&b, "DTSTART;VALUE=DATE:"+a.StartTime.UTC().Format(icalDate))
+			icalLine(&b, "DTEND;VALUE=DATE:"+a.EndTime.UTC().Format(icalDate))
+		} else {
+			icalLine(&b, "DTSTART:"+a.StartTime.UTC().Format(icalDateTime))
+			icalLine(&b, "DTEND:"+a.EndTime.UTC().Format(icalDateTime))
+		}
+		icalLine(&b, "SUMMARY:"+icalText(a.Title))
+		if description := appointmentDescription(a); description != "" {
+			icalLine(&b, "DESCRIPTION:"+icalText(description))
+		}
+		if a.Location != "" {
+			icalLine(&b, "LOCATION:"+icalText(a.Location))
+		}
+		icalLine(&b, "END:VEVENT")
+	}
+	icalLine(&b, "END:VCALENDAR")
+	return []byte(b.String())
+}
+
+func appointmentDescription(a *models.CalendarAppointment) string {
+	if len(a.Tickets) == 0 {
+		return a.Description
+	}
+	lines := make([]string, 0, len(a.Tickets)+2)
+	if a.Description != "" {
+		lines = append(lines, a.Description, "")
+	}
+	for _, t := range a.Tickets {
+		lines = append(lines, "Ticket#"+t.Number+": "+t.Title)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// icalText escapes a TEXT property value.
+func icalText(s string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+		"\r", "",
+	).Replace(s)
+}
+
+// icalLine writes a content line, folding it at 75 octets without
+// splitting UTF-8 sequences.
+func icalLine(b *strings.Builder, line string) {
+	limit := icalLineLimit
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && line[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		b.WriteString(line[:cut])
+		b.WriteString("\r\n ")
+		line = line[cut:]
+		// Continuation lines start with a space, which counts
+		limit = icalLineLimit - 1
+	}
+	b.WriteString(line)
+	b.WriteString("\r\n")
+}
+
+// ParseICalendarTime parses an iCalendar UTC date-time or date, as used in
+// CalDAV time-range queries.
+func ParseICalendarTime(s string) (time.Time, error) {
+	if len(s) == len(icalDate) {
+		return time.Parse(icalDate, s)
+	}
+	return time.Parse(icalDateTime, s)
+}
@@ -0,0 +1,364 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/repository"
+)
+
+// Errors returned by CalendarService.
+var (
+	ErrCalendarNotFound    = errors.New("calendar not found")
+	ErrCalendarNameExists  = errors.New("a calendar with this name already exists")
+	ErrCalendarInvalid     = errors.New("invalid calendar")
+	ErrAppointmentNotFound = errors.New("appointment not found")
+	ErrAppointmentInvalid  = errors.New("invalid appointment")
+)
+
+const (
+	// CalendarDefaultColor is used for calendars that set no color.
+	CalendarDefaultColor = "#3A87AD"
+
+	// calendarMaxAttendees and calendarMaxTickets cap the attendees and
+	// linked tickets of one appointment.
+	calendarMaxAttendees = 100
+	calendarMaxTickets   = 50
+
+	// calendarFeedPast and calendarFeedFuture bound the appointments
+	// published in iCal feeds.
+	calendarFeedPast   = 90 * 24 * time.Hour
+	calendarFeedFuture = 2 * 365 * 24 * time.Hour
+)
+
+var calendarColor = regexp.MustCompile(`^#[0-9A-Fa-f]{6}$`)
+
+// CalendarService manages team calendars and their appointments. Access
+// checks are left to callers, which know the agent's groups.
+type CalendarService struct {
+	repo *repository.CalendarRepository
+	now  func() time.Time
+}
+
+// NewCalendarService creates a calendar service.
+func NewCalendarService(db *sql.DB) *CalendarService {
+	return &CalendarService{repo: repository.NewCalendarRepository(db), now: time.Now}
+}
+
+// ListCalendars returns the calendars of the groups, or all calendars when
+// groupIDs is nil.
+func (s *CalendarService) ListCalendars(ctx context.Context, groupIDs []int, validOnly bool) ([]*models.Calendar, error) {
+	return s.repo.ListCalendars(ctx, groupIDs, validOnly)
+}
+
+// GetCalendar returns a calendar.
+func (s *CalendarService) GetCalendar(ctx context.Context, id int64) (*models.Calendar, error) {
+	cal, err := s.repo.GetCalendar(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if cal == nil {
+		return nil, ErrCalendarNotFound
+	}
+	return cal, nil
+}
+
+// CreateCalendar validates and stores a new calendar.
+func (s *CalendarService) CreateCalendar(ctx context.Context, cal *models.Calendar, userID int) error {
+	if err := s.validateCalendar(ctx, cal); err != nil {
+		return err
+	}
+	salt, err := newCalendarSalt()
+	if err != nil {
+		return err
+	}
+	now := s.now()
+	cal.SaltString = salt
+	cal.CreateTime, cal.CreateBy = now, userID
+	cal.ChangeTime, cal.ChangeBy = now, userID
+	return s.repo.CreateCalendar(ctx, cal)
+}
+
+// UpdateCalendar validates and stores changes to a calendar. Its feed
+// tokens stay valid.
+func (s *CalendarService) UpdateCalendar(ctx context.Context, cal *models.Calendar, userID int) error {
+	existing, err := s.GetCalendar(ctx, cal.ID)
+	if err != nil {
+		return err
+	}
+	if err := s.validateCalendar(ctx, cal); err != nil {
+		return err
+	}
+	cal.SaltString = existing.SaltString
+	cal.CreateTime, cal.CreateBy = existing.CreateTime, existing.CreateBy
+	cal.ChangeTime, cal.ChangeBy = s.now(), userID
+	if err := s.repo.UpdateCalendar(ctx, cal); err == sql.ErrNoRows {
+		return ErrCalendarNotFound
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+// DeleteCalendar deletes a calendar with all its appointments.
+func (s *CalendarService) DeleteCalendar(ctx context.Context, id int64) error {
+	deleted, err := s.repo.DeleteCalendar(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrCalendarNotFound
+	}
+	return nil
+}
+
+// ResetFeedTokens invalidates all feed tokens of a calendar, e.g. after a
+// feed URL leaked.
+func (s *CalendarService) ResetFeedTokens(ctx context.Context, id int64) error {
+	salt, err := newCalendarSalt()
+	if err != nil {
+		return err
+	}
+	if err := s.repo.SetCalendarSalt(ctx, id, salt); err == sql.ErrNoRows {
+		return ErrCalendarNotFound
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+// FeedToken returns the token of an agent's iCal feed of a calendar.
+// Calendars imported without a salt get one first.
+func (s *CalendarService) FeedToken(ctx context.Context, cal *models.Calendar, userID int) (string, error) {
+	if cal.SaltString == "" {
+		salt, err := newCalendarSalt()
+		if err != nil {
+			return "", err
+		}
+		if err := s.repo.SetCalendarSalt(ctx, cal.ID, salt); err != nil {
+			return "", err
+		}
+		cal.SaltString = salt
+	}
+	return calendarFeedToken(cal, userID), nil
+}
+
+// VerifyFeedToken reports whether token is the agent's feed token of the
+// calendar.
+func (s *CalendarService) VerifyFeedToken(cal *models.Calendar, userID int, token string) bool {
+	if cal.SaltString == "" || token == "" {
+		return false
+	}
+	return hmac.Equal([]byte(calendarFeedToken(cal, userID)), []byte(token))
+}
+
+// ValidAgent reports whether the agent exists and is valid. Feeds stop
+// working for agents that were disabled.
+func (s *CalendarService) ValidAgent(ctx context.Context, userID int) (bool, error) {
+	n, err := s.repo.CountValidUsers(ctx, []int{userID})
+	return n == 1, err
+}
+
+// FeedAppointments returns the appointments published in a calendar's
+// iCal feed: those from 90 days ago to two years ahead.
+func (s *CalendarService) FeedAppointments(ctx context.Context, cal *models.Calendar) ([]*models.CalendarAppointment, error) {
+	now := s.now()
+	return s.repo.ListAppointments(ctx, models.CalendarAppointmentFilter{
+		CalendarIDs: []int64{cal.ID},
+		From:        now.Add(-calendarFeedPast),
+		To:          now.Add(calendarFeedFuture),
+	})
+}
+
+// ListAppointments returns the appointments matching the filter.
+func (s *CalendarService) ListAppointments(ctx context.Context, filter models.CalendarAppointmentFilter) ([]*models.CalendarAppointment, error) {
+	return s.repo.ListAppointments(ctx, filter)
+}
+
+// GetAppointment returns an appointment.
+func (s *CalendarService) GetAppointment(ctx context.Context, id int64) (*models.CalendarAppointment, error) {
+	a, err := s.repo.GetAppointment(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if a == nil {
+		return nil, ErrAppointmentNotFound
+	}
+	return a, nil
+}
+
+// CreateAppointment validates and stores a new appointment.
+func (s *CalendarService) CreateAppointment(ctx context.Context, a *models.CalendarAppointment, userID int) error {
+	if err := s.validateAppointment(ctx, a); err != nil {
+		return err
+	}
+	now := s.now()
+	a.ParentID = 0
+	a.UniqueID = uuid.NewString()
+	a.CreateTime, a.CreateBy = now, userID
+	a.ChangeTime, a.ChangeBy = now, userID
+	return s.repo.CreateAppointment(ctx, a)
+}
+
+// UpdateAppointment validates and stores changes to an appointment,
+// replacing its attendees and ticket links.
+func (s *CalendarService) UpdateAppointment(ctx context.Context, a *models.CalendarAppointment, userID int) error {
+	existing, err := s.GetAppointment(ctx, a.ID)
+	if err != nil {
+		return err
+	}
+	if err := s.validateAppointment(ctx, a); err != nil {
+		return err
+	}
+	a.ParentID, a.UniqueID = existing.ParentID, existing.UniqueID
+	a.CreateTime, a.CreateBy = existing.CreateTime, existing.CreateBy
+	a.ChangeTime, a.ChangeBy = s.now(), userID
+	if err := s.repo.UpdateAppointment(ctx, a); err == sql.ErrNoRows {
+		return ErrAppointmentNotFound
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+// DeleteAppointment deletes an appointment.
+func (s *CalendarService) DeleteAppointment(ctx context.Context, id int64) error {
+	deleted, err := s.repo.DeleteAppointment(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrAppointmentNotFound
+	}
+	return nil
+}
+
+// validateCalendar checks a calendar and fills in defaults.
+func (s *CalendarService) validateCalendar(ctx context.Context, cal *models.Calendar) error {
+	cal.Name = strings.TrimSpace(cal.Name)
+	cal.Color = strings.ToUpper(strings.TrimSpace(cal.Color))
+	if cal.Color == "" {
+		cal.Color = CalendarDefaultColor
+	}
+	switch {
+	case cal.Name == "" || len(cal.Name) > 200:
+		return fmt.Errorf("%w: name must be 1 to 200 characters", ErrCalendarInvalid)
+	case cal.GroupID <= 0:
+		return fmt.Errorf("%w: group_id is required", ErrCalendarInvalid)
+	case !calendarColor.MatchString(cal.Color):
+		return fmt.Errorf("%w: color must have the form #RRGGBB", ErrCalendarInvalid)
+	}
+	if cal.ValidID == 0 {
+		cal.ValidID = 1
+	}
+	exists, err := s.repo.CalendarNameExists(ctx, cal.Name, cal.ID)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return ErrCalendarNameExists
+	}
+	return nil
+}
+
+// validateAppointment checks an appointment and normalizes its times.
+// All-day appointments are moved to whole UTC days, ending after their
+// last day.
+func (s *CalendarService) validateAppointment(ctx context.Context, a *models.CalendarAppointment) error {
+	a.Title = strings.TrimSpace(a.Title)
+	a.Location = strings.TrimSpace(a.Location)
+	switch {
+	case a.Title == "" || len(a.Title) > 255:
+		return fmt.Errorf("%w: title must be 1 to 255 characters", ErrAppointmentInvalid)
+	case len(a.Location) > 255:
+		return fmt.Errorf("%w: location must be at most 255 characters", ErrAppointmentInvalid)
+	case a.StartTime.IsZero() || a.EndTime.IsZero():
+		return fmt.Errorf("%w: start_time and end_time are required", ErrAppointmentInvalid)
+	}
+	a.StartTime, a.EndTime = a.StartTime.UTC(), a.EndTime.UTC()
+	if a.AllDay {
+		a.StartTime = a.StartTime.Truncate(24 * time.Hour)
+		a.EndTime = a.EndTime.Add(-time.Nanosecond).Truncate(24 * time.Hour).Add(24 * time.Hour)
+		if !a.EndTime.After(a.StartTime) {
+			a.EndTime = a.StartTime.Add(24 * time.Hour)
+		}
+	}
+	if !a.EndTime.After(a.StartTime) {
+		return fmt.Errorf("%w: end_time must be after start_time", ErrAppointmentInvalid)
+	}
+
+	cal, err := s.repo.GetCalendar(ctx, a.CalendarID)
+	if err != nil {
+		return err
+	}
+	if cal == nil {
+		return ErrCalendarNotFound
+	}
+	if cal.ValidID != 1 {
+		return fmt.Errorf("%w: calendar %q is disabled", ErrAppointmentInvalid, cal.Name)
+	}
+
+	a.AttendeeIDs = uniqueInts(a.AttendeeIDs)
+	if len(a.AttendeeIDs) > calendarMaxAttendees {
+		return fmt.Errorf("%w: at most %d attendees", ErrAppointmentInvalid, calendarMaxAttendees)
+	}
+	if n, err := s.repo.CountValidUsers(ctx, a.AttendeeIDs); err != nil {
+		return err
+	} else if n != len(a.AttendeeIDs) {
+		return fmt.Errorf("%w: unknown or invalid attendee", ErrAppointmentInvalid)
+	}
+
+	ticketIDs := uniqueInt64s(a.TicketIDs())
+	if len(ticketIDs) > calendarMaxTickets {
+		return fmt.Errorf("%w: at most %d linked tickets", ErrAppointmentInvalid, calendarMaxTickets)
+	}
+	if n, err := s.repo.CountTickets(ctx, ticketIDs); err != nil {
+		return err
+	} else if n != len(ticketIDs) {
+		return fmt.Errorf("%w: unknown ticket", ErrAppointmentInvalid)
+	}
+	a.Tickets = make([]models.CalendarAppointmentTicket, len(ticketIDs))
+	for i, id := range ticketIDs {
+		a.Tickets[i] = models.CalendarAppointmentTicket{ID: id}
+	}
+	return nil
+}
+
+func calendarFeedToken(cal *models.Calendar, userID int) string {
+	mac := hmac.New(sha256.New, []byte(cal.SaltString))
+	mac.Write([]byte(strconv.FormatInt(cal.ID, 10) + ":" + strconv.Itoa(userID)))
+	return hex.EncodeToString(mac.Sum(nil))[:32]
+}
+
+func newCalendarSalt() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+func uniqueInt64s(ids []int64) []int64 {
+	seen := make(map[int64]bool, len(ids))
+	out := make([]int64, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			out = append(out, id)
+		}
+	}
+	return out
+}
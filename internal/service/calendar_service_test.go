@@ -0,0 +1,153 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/testutil"
+)
+
+func TestCalendarService(t *testing.T) {
+	db := testutil.UseMigratedDB(t)
+	_, err := db.Exec(`INSERT INTO users (id, login, pw, first_name, last_name, valid_id, create_time, create_by, change_time, change_by)
+		VALUES (1, 'root@localhost', 'x', 'Admin', 'OTRS', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1),
+		       (2, 'gone', 'x', 'Gone', 'Agent', 2, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	now := time.Date(2026, 3, 10, 9, 0, 0, 0, time.UTC)
+	s := NewCalendarService(db)
+	s.now = func() time.Time { return now }
+
+	for name, cal := range map[string]*models.Calendar{
+		"no name":   {GroupID: 1},
+		"no group":  {Name: "Support"},
+		"bad color": {Name: "Support", GroupID: 1, Color: "blue"},
+	} {
+		assert.ErrorIs(t, s.CreateCalendar(ctx, cal, 1), ErrCalendarInvalid, name)
+	}
+
+	cal := &models.Calendar{Name: " Support ", GroupID: 1, Color: "#ff8800"}
+	require.NoError(t, s.CreateCalendar(ctx, cal, 1))
+	assert.Equal(t, "Support", cal.Name)
+	assert.Equal(t, "#FF8800", cal.Color)
+	assert.Equal(t, 1, cal.ValidID)
+	assert.Len(t, cal.SaltString, 64)
+	assert.ErrorIs(t, s.CreateCalendar(ctx, &models.Calendar{Name: "Support", GroupID: 1}, 1), ErrCalendarNameExists)
+
+	token, err := s.FeedToken(ctx, cal, 1)
+	require.NoError(t, err)
+	assert.Len(t, token, 32)
+	assert.True(t, s.VerifyFeedToken(cal, 1, token))
+	assert.False(t, s.VerifyFeedToken(cal, 2, token), "tokens are per agent")
+	assert.False(t, s.VerifyFeedToken(cal, 1, ""))
+
+	cal.Color = ""
+	require.NoError(t, s.UpdateCalendar(ctx, cal, 1))
+	assert.Equal(t, CalendarDefaultColor, cal.Color)
+	cal, err = s.GetCalendar(ctx, cal.ID)
+	require.NoError(t, err)
+	assert.True(t, s.VerifyFeedToken(cal, 1, token), "updates keep feed tokens")
+	require.NoError(t, s.ResetFeedTokens(ctx, cal.ID))
+	cal, err = s.GetCalendar(ctx, cal.ID)
+	require.NoError(t, err)
+	assert.False(t, s.VerifyFeedToken(cal, 1, token))
+	assert.ErrorIs(t, s.ResetFeedTokens(ctx, 999), ErrCalendarNotFound)
+
+	start := now.Add(24 * time.Hour)
+	for name, a := range map[string]*models.CalendarAppointment{
+		"no title":       {CalendarID: cal.ID, StartTime: start, EndTime: start.Add(time.Hour)},
+		"no times":       {CalendarID: cal.ID, Title: "Review"},
+		"ends too early": {CalendarID: cal.ID, Title: "Review", StartTime: start, EndTime: start},
+		"bad attendee":   {CalendarID: cal.ID, Title: "Review", StartTime: start, EndTime: start.Add(time.Hour), AttendeeIDs: []int{2}},
+		"bad ticket": {CalendarID: cal.ID, Title: "Review", StartTime: start, EndTime: start.Add(time.Hour),
+			Tickets: []models.CalendarAppointmentTicket{{ID: 42}}},
+	} {
+		assert.ErrorIs(t, s.CreateAppointment(ctx, a, 1), ErrAppointmentInvalid, name)
+	}
+	assert.ErrorIs(t, s.CreateAppointment(ctx, &models.CalendarAppointment{CalendarID: 999, Title: "Review",
+		StartTime: start, EndTime: start.Add(time.Hour)}, 1), ErrCalendarNotFound)
+
+	a := &models.CalendarAppointment{CalendarID: cal.ID, Title: "Review", StartTime: start, EndTime: start.Add(time.Hour),
+		AttendeeIDs: []int{1, 1}}
+	require.NoError(t, s.CreateAppointment(ctx, a, 1))
+	assert.NotEmpty(t, a.UniqueID)
+	assert.Equal(t, []int{1}, a.AttendeeIDs)
+	uid := a.UniqueID
+
+	a.AllDay = true
+	a.EndTime = start.Add(2 * time.Hour)
+	require.NoError(t, s.UpdateAppointment(ctx, a, 1))
+	assert.Equal(t, uid, a.UniqueID)
+	assert.Equal(t, time.Date(2026, 3, 11, 0, 0, 0, 0, time.UTC), a.StartTime)
+	assert.Equal(t, time.Date(2026, 3, 12, 0, 0, 0, 0, time.UTC), a.EndTime)
+
+	valid, err := s.ValidAgent(ctx, 1)
+	require.NoError(t, err)
+	assert.True(t, valid)
+	valid, err = s.ValidAgent(ctx, 2)
+	require.NoError(t, err)
+	assert.False(t, valid)
+
+	feed, err := s.FeedAppointments(ctx, cal)
+	require.NoError(t, err)
+	require.Len(t, feed, 1)
+	assert.True(t, feed[0].AllDay)
+
+	require.NoError(t, s.DeleteAppointment(ctx, a.ID))
+	assert.ErrorIs(t, s.DeleteAppointment(ctx, a.ID), ErrAppointmentNotFound)
+	_, err = s.GetAppointment(ctx, a.ID)
+	assert.ErrorIs(t, err, ErrAppointmentNotFound)
+	require.NoError(t, s.DeleteCalendar(ctx, cal.ID))
+	assert.ErrorIs(t, s.DeleteCalendar(ctx, cal.ID), ErrCalendarNotFound)
+}
+
+func TestRenderICalendar(t *testing.T) {
+	created := time.Date(2026, 3, 1, 8, 0, 0, 0, time.UTC)
+	ics := string(RenderICalendar("Support, 2nd level", []*models.CalendarAppointment{
+		{
+			UniqueID: "uid-1", Title: "Call; customer", Description: "Bring notes",
+			Location:   "Room 1",
+			StartTime:  time.Date(2026, 3, 10, 9, 0, 0, 0, time.FixedZone("CET", 3600)),
+			EndTime:    time.Date(2026, 3, 10, 10, 0, 0, 0, time.FixedZone("CET", 3600)),
+			Tickets:    []models.CalendarAppointmentTicket{{ID: 1, Number: "2026030110000001", Title: "Printer"}},
+			CreateTime: created, ChangeTime: created,
+		},
+		{
+			UniqueID: "uid-2", Title: strings.Repeat("Offsite ", 12), AllDay: true,
+			StartTime:  time.Date(2026, 3, 12, 0, 0, 0, 0, time.UTC),
+			EndTime:    time.Date(2026, 3, 13, 0, 0, 0, 0, time.UTC),
+			CreateTime: created, ChangeTime: created,
+		},
+	}))
+
+	assert.True(t, strings.HasPrefix(ics, "BEGIN:VCALENDAR\r\nVERSION:2.0\r\n"))
+	assert.True(t, strings.HasSuffix(ics, "END:VCALENDAR\r\n"))
+	assert.Contains(t, ics, "X-WR-CALNAME:Support\\, 2nd level\r\n")
+	assert.Contains(t, ics, "DTSTART:20260310T080000Z\r\n")
+	assert.Contains(t, ics, "SUMMARY:Call\\; customer\r\n")
+	assert.Contains(t, ics, "DESCRIPTION:Bring notes\\n\\nTicket#2026030110000001: Printer\r\n")
+	assert.Contains(t, ics, "DTSTART;VALUE=DATE:20260312\r\n")
+	assert.Contains(t, ics, "DTEND;VALUE=DATE:20260313\r\n")
+	for _, line := range strings.Split(ics, "\r\n") {
+		assert.LessOrEqual(t, len(line), 75)
+	}
+	assert.Contains(t, ics, "Off\r\n site Offsite")
+}
+
+func TestParseICalendarTime(t *testing.T) {
+	got, err := ParseICalendarTime("20260310T080000Z")
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2026, 3, 10, 8, 0, 0, 0, time.UTC), got)
+	got, err = ParseICalendarTime("20260310")
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC), got)
+	_, err = ParseICalendarTime("tomorrow")
+	assert.Error(t, err)
+}
@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/goatkit/goatflow/internal/sysconfig"
+)
+
+// CaptchaVerifier checks the response a CAPTCHA widget posted with a form.
+type CaptchaVerifier interface {
+	Verify(ctx context.Context, response, remoteIP string) (bool, error)
+}
+
+// CaptchaProvider creates the verifier of a CAPTCHA provider from its
+// settings.
+type CaptchaProvider func(cfg sysconfig.CaptchaConfig) CaptchaVerifier
+
+var (
+	captchaProvidersMu sync.RWMutex
+	captchaProviders   = map[string]CaptchaProvider{
+		"turnstile": siteverifyProvider("https://challenges.cloudflare.com/turnstile/v0/siteverify"),
+		"hcaptcha":  siteverifyProvider("https://api.hcaptcha.com/siteverify"),
+		"recaptcha": siteverifyProvider("https://www.google.com/recaptcha/api/siteverify"),
+	}
+)
+
+// RegisterCaptchaProvider adds or replaces a CAPTCHA provider, so
+// installations can plug in a service the built-in providers do not cover.
+func RegisterCaptchaProvider(name string, provider CaptchaProvider) {
+	captchaProvidersMu.Lock()
+	defer captchaProvidersMu.Unlock()
+	captchaProviders[name] = provider
+}
+
+// CaptchaProviders returns the names of the registered CAPTCHA providers.
+func CaptchaProviders() []string {
+	captchaProvidersMu.RLock()
+	defer captchaProvidersMu.RUnlock()
+	names := make([]string, 0, len(captchaProviders))
+	for name := range captchaProviders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewCaptchaVerifier returns the verifier of the configured provider, or
+// nil when no CAPTCHA is configured.
+func NewCaptchaVerifier(cfg sysconfig.CaptchaConfig) (CaptchaVerifier, error) {
+	if cfg.Provider == "" {
+		return nil, nil
+	}
+	captchaProvidersMu.RLock()
+	provider, ok := captchaProviders[cfg.Provider]
+	captchaProvidersMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown captcha provider %q", cfg.Provider)
+	}
+	return provider(cfg), nil
+}
+
+func siteverifyProvider(endpoint string) CaptchaProvider {
+	return func(cfg sysconfig.CaptchaConfig) CaptchaVerifier {
+		return &SiteverifyCaptcha{
+			URL:    endpoint,
+			Secret: cfg.Secret,
+			Client: &http.Client{Timeout: 5 * time.Second},
+		}
+	}
+}
+
+// SiteverifyCaptcha verifies responses with the siteverify API shared by
+// Cloudflare Turnstile, hCaptcha and Google reCAPTCHA.
+type SiteverifyCaptcha struct {
+	URL    string
+	Secret string
+	Client *http.Client
+}
+
+// Verify reports whether the provider accepted the response.
+func (v *SiteverifyCaptcha) Verify(ctx context.Context, response, remoteIP string) (bool, error) {
+	if response == "" {
+		return false, nil
+	}
+	form := url.Values{"secret": {v.Secret}, "response": {response}}
+	if remoteIP != "" {
+		form.Set("remoteip", remoteIP)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, v.URL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := v.Client.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("captcha verification: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("captcha verification: unexpected status %d", resp.StatusCode)
+	}
+	var result struct {
+		Success bool `json:"success"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return false, fmt.Errorf("captcha verification: %w", err)
+	}
+	return result.Success, nil
+}
@@ -0,0 +1,218 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/goatkit/goatflow/internal/history"
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/repository"
+)
+
+// MaxChangeCalendarRange is the longest period the change calendar covers.
+const MaxChangeCalendarRange = 366 * 24 * time.Hour
+
+// Errors returned by ChangeService.
+var (
+	ErrChangeNotFound        = errors.New("ticket has no change plan")
+	ErrChangeTicketNotFound  = errors.New("ticket not found")
+	ErrChangeWindowRequired  = errors.New("planned_start and planned_end are required")
+	ErrChangeInvalidWindow   = errors.New("planned_end must be after planned_start")
+	ErrChangeInvalidRisk     = errors.New("risk must be one of low, medium, high, critical")
+	ErrChangeInvalidService  = errors.New("impacted services must be valid services")
+	ErrChangeInvalidItem     = errors.New("configuration item names must be 1 to 200 characters")
+	ErrChangeInvalidCalendar = errors.New("calendar range must end after it starts and cover at most 366 days")
+)
+
+// ChangeService manages change plans: the planned window, risk and impacted
+// services and configuration items of change tickets. Plans are checked
+// against each other so that two changes touching the same service or item
+// in overlapping windows are reported as conflicts. Approvals for changes
+// use the regular ticket approval rules.
+type ChangeService struct {
+	repo *repository.ChangeRepository
+	// recordHistory adds a ticket history entry; tests replace it.
+	recordHistory func(ctx context.Context, ticketID int, historyType, message string, userID int)
+}
+
+// NewChangeService creates a change service.
+func NewChangeService(db *sql.DB) *ChangeService {
+	recorder := history.NewRecorder(repository.NewTicketRepository(db))
+	return &ChangeService{
+		repo: repository.NewChangeRepository(db),
+		recordHistory: func(ctx context.Context, ticketID int, historyType, message string, userID int) {
+			if err := recorder.RecordByTicketID(ctx, nil, ticketID, nil, historyType, message, userID); err != nil {
+				log.Printf("change: history for ticket %d failed: %v", ticketID, err)
+			}
+		},
+	}
+}
+
+// Get returns the change plan of a ticket with its conflicts.
+func (s *ChangeService) Get(ctx context.Context, ticketID int) (*models.ChangePlan, error) {
+	plan, err := s.repo.Get(ctx, ticketID)
+	if err != nil {
+		return nil, err
+	}
+	if plan == nil {
+		return nil, ErrChangeNotFound
+	}
+	if plan.Conflicts, err = s.Conflicts(ctx, plan); err != nil {
+		return nil, err
+	}
+	return plan, nil
+}
+
+// Save validates and stores the change plan of a ticket, replacing any
+// earlier plan. Conflicts do not stop the plan from being saved; they are
+// returned on the saved plan as warnings.
+func (s *ChangeService) Save(ctx context.Context, plan *models.ChangePlan, userID int) (*models.ChangePlan, error) {
+	exists, err := s.repo.TicketExists(ctx, plan.TicketID)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrChangeTicketNotFound
+	}
+	if err := s.validate(ctx, plan); err != nil {
+		return nil, err
+	}
+	if err := s.repo.Save(ctx, plan, userID); err != nil {
+		return nil, err
+	}
+
+	saved, err := s.Get(ctx, plan.TicketID)
+	if err != nil {
+		return nil, err
+	}
+	message := fmt.Sprintf("Change planned from %s to %s (risk %s)",
+		saved.PlannedStart.UTC().Format(time.RFC3339), saved.PlannedEnd.UTC().Format(time.RFC3339), saved.Risk)
+	if n := len(saved.Conflicts); n > 0 {
+		message += fmt.Sprintf("; conflicts with %d other change(s)", n)
+	}
+	s.recordHistory(ctx, plan.TicketID, history.TypeMisc, message, userID)
+	return saved, nil
+}
+
+// Delete removes the change plan of a ticket.
+func (s *ChangeService) Delete(ctx context.Context, ticketID, userID int) error {
+	if err := s.repo.Delete(ctx, ticketID); errors.Is(err, sql.ErrNoRows) {
+		return ErrChangeNotFound
+	} else if err != nil {
+		return err
+	}
+	s.recordHistory(ctx, ticketID, history.TypeMisc, "Change plan removed", userID)
+	return nil
+}
+
+func (s *ChangeService) validate(ctx context.Context, plan *models.ChangePlan) error {
+	if plan.PlannedStart.IsZero() || plan.PlannedEnd.IsZero() {
+		return ErrChangeWindowRequired
+	}
+	if !plan.PlannedEnd.After(plan.PlannedStart) {
+		return ErrChangeInvalidWindow
+	}
+	if plan.Risk == "" {
+		plan.Risk = models.ChangeRiskMedium
+	}
+	if !plan.Risk.IsValid() {
+		return ErrChangeInvalidRisk
+	}
+	plan.ImplementationPlan = strings.TrimSpace(plan.ImplementationPlan)
+	plan.BackoutPlan = strings.TrimSpace(plan.BackoutPlan)
+
+	services := make([]int, 0, len(plan.ServiceIDs))
+	seenServices := map[int]bool{}
+	for _, id := range plan.ServiceIDs {
+		if seenServices[id] {
+			continue
+		}
+		seenServices[id] = true
+		if ok, err := s.repo.ServiceExists(ctx, id); err != nil {
+			return err
+		} else if !ok {
+			return ErrChangeInvalidService
+		}
+		services = append(services, id)
+	}
+	plan.ServiceIDs = services
+
+	items := make([]string, 0, len(plan.ConfigItems))
+	seenItems := map[string]bool{}
+	for _, name := range plan.ConfigItems {
+		name = strings.TrimSpace(name)
+		if name == "" || len(name) > 200 {
+			return ErrChangeInvalidItem
+		}
+		key := models.ChangeConfigItemKey(name)
+		if seenItems[key] {
+			continue
+		}
+		seenItems[key] = true
+		items = append(items, name)
+	}
+	plan.ConfigItems = items
+	return nil
+}
+
+// Conflicts returns the open changes that touch a service or configuration
+// item of the plan in an overlapping window.
+func (s *ChangeService) Conflicts(ctx context.Context, plan *models.ChangePlan) ([]models.ChangeConflict, error) {
+	others, err := s.repo.List(ctx, models.ChangeCalendarFilter{
+		From:       plan.PlannedStart,
+		To:         plan.PlannedEnd,
+		ActiveOnly: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return conflictsAmong(plan, others), nil
+}
+
+// Calendar returns the changes planned in the filter's range, each with its
+// conflicts. Conflicts are found among all open changes, including ones the
+// filter leaves out.
+func (s *ChangeService) Calendar(ctx context.Context, filter models.ChangeCalendarFilter) ([]models.ChangePlan, error) {
+	if !filter.To.After(filter.From) || filter.To.Sub(filter.From) > MaxChangeCalendarRange {
+		return nil, ErrChangeInvalidCalendar
+	}
+	plans, err := s.repo.List(ctx, filter)
+	if err != nil || len(plans) == 0 {
+		return plans, err
+	}
+
+	// One query covering every listed window is enough to find all
+	// conflicts.
+	from, to := plans[0].PlannedStart, plans[0].PlannedEnd
+	for _, p := range plans {
+		if p.PlannedStart.Before(from) {
+			from = p.PlannedStart
+		}
+		if p.PlannedEnd.After(to) {
+			to = p.PlannedEnd
+		}
+	}
+	open, err := s.repo.List(ctx, models.ChangeCalendarFilter{From: from, To: to, ActiveOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	for i := range plans {
+		plans[i].Conflicts = conflictsAmong(&plans[i], open)
+	}
+	return plans, nil
+}
+
+func conflictsAmong(plan *models.ChangePlan, others []models.ChangePlan) []models.ChangeConflict {
+	conflicts := make([]models.ChangeConflict, 0)
+	for i := range others {
+		if c := plan.ConflictWith(&others[i]); c != nil {
+			conflicts = append(conflicts, *c)
+		}
+	}
+	return conflicts
+}
@@ -0,0 +1,192 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/testutil"
+)
+
+func newChangeTestService(t *testing.T) (*ChangeService, *[]string) {
+	t.Helper()
+	db := testutil.MigratedDB(t)
+	for _, stmt := range []string{
+		`INSERT INTO users (id, login, pw, first_name, last_name, valid_id, create_time, create_by, change_time, change_by)
+			VALUES (1, 'root@localhost', 'x', 'Admin', 'OTRS', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1),
+			       (5, 'agent5', 'x', 'Agent', 'Five', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+		`INSERT INTO service (id, name, valid_id, create_time, create_by, change_time, change_by)
+			VALUES (1, 'Email', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1),
+			       (2, 'VPN', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1),
+			       (3, 'Fax', 2, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+		`INSERT INTO ticket_approval_rule (id, name, action, approver_group_id, valid_id,
+			create_time, create_by, change_time, change_by)
+			VALUES (1, 'Changes', 'close', 2, 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+	} {
+		_, err := db.Exec(stmt)
+		require.NoError(t, err, stmt)
+	}
+	// Ticket 3 is closed, the others open.
+	for _, tk := range []struct {
+		id, queueID, stateID int
+		title                string
+	}{
+		{1, 1, 2, "Upgrade mail server"},
+		{2, 2, 2, "Patch database"},
+		{3, 1, 4, "Old mail change"},
+		{4, 1, 2, "VPN certificate renewal"},
+	} {
+		_, err := db.Exec(`INSERT INTO ticket (id, tn, title, queue_id, ticket_lock_id, user_id, responsible_user_id,
+			ticket_priority_id, ticket_state_id, timeout, until_time, escalation_time, escalation_update_time,
+			escalation_response_time, escalation_solution_time, archive_flag, create_time, create_by, change_time, change_by)
+			VALUES (?, ?, ?, ?, 1, 1, 1, 3, ?, 0, 0, 0, 0, 0, 0, 0, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+			tk.id, fmt.Sprint(1000+tk.id), tk.title, tk.queueID, tk.stateID)
+		require.NoError(t, err)
+	}
+	_, err := db.Exec(`INSERT INTO ticket_approval_request (id, rule_id, ticket_id, action, target_id, status,
+		requested_by, requested_time) VALUES (1, 1, 2, 'close', 4, 'rejected', 1, CURRENT_TIMESTAMP),
+		(2, 1, 2, 'close', 4, 'approved', 1, CURRENT_TIMESTAMP)`)
+	require.NoError(t, err)
+
+	svc := NewChangeService(db)
+	var history []string
+	svc.recordHistory = func(_ context.Context, _ int, _ string, message string, _ int) {
+		history = append(history, message)
+	}
+	return svc, &history
+}
+
+func changeWindow(startHour, endHour int) (time.Time, time.Time) {
+	day := time.Date(2026, 11, 2, 0, 0, 0, 0, time.UTC)
+	return day.Add(time.Duration(startHour) * time.Hour), day.Add(time.Duration(endHour) * time.Hour)
+}
+
+func TestChangeService_SaveValidates(t *testing.T) {
+	svc, _ := newChangeTestService(t)
+	ctx := context.Background()
+	start, end := changeWindow(20, 22)
+
+	tests := []struct {
+		name string
+		plan models.ChangePlan
+		want error
+	}{
+		{"missing ticket", models.ChangePlan{TicketID: 99, PlannedStart: start, PlannedEnd: end}, ErrChangeTicketNotFound},
+		{"missing window", models.ChangePlan{TicketID: 1}, ErrChangeWindowRequired},
+		{"end before start", models.ChangePlan{TicketID: 1, PlannedStart: end, PlannedEnd: start}, ErrChangeInvalidWindow},
+		{"unknown risk", models.ChangePlan{TicketID: 1, PlannedStart: start, PlannedEnd: end, Risk: "extreme"}, ErrChangeInvalidRisk},
+		{"invalid service", models.ChangePlan{TicketID: 1, PlannedStart: start, PlannedEnd: end, ServiceIDs: []int{3}}, ErrChangeInvalidService},
+		{"blank item", models.ChangePlan{TicketID: 1, PlannedStart: start, PlannedEnd: end, ConfigItems: []string{" "}}, ErrChangeInvalidItem},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan := tt.plan
+			_, err := svc.Save(ctx, &plan, 1)
+			assert.ErrorIs(t, err, tt.want)
+		})
+	}
+}
+
+func TestChangeService_SaveReportsConflicts(t *testing.T) {
+	svc, history := newChangeTestService(t)
+	ctx := context.Background()
+
+	start, end := changeWindow(20, 23)
+	_, err := svc.Save(ctx, &models.ChangePlan{
+		TicketID: 2, PlannedStart: start, PlannedEnd: end, Risk: models.ChangeRiskHigh,
+		ConfigItems: []string{"db01.example.com"},
+	}, 1)
+	require.NoError(t, err)
+
+	// Closed changes and changes that only touch other items never conflict.
+	_, err = svc.Save(ctx, &models.ChangePlan{
+		TicketID: 3, PlannedStart: start, PlannedEnd: end, ServiceIDs: []int{1}, ConfigItems: []string{"db01.example.com"},
+	}, 1)
+	require.NoError(t, err)
+	_, err = svc.Save(ctx, &models.ChangePlan{TicketID: 4, PlannedStart: start, PlannedEnd: end, ServiceIDs: []int{2}}, 1)
+	require.NoError(t, err)
+
+	start, end = changeWindow(22, 26)
+	saved, err := svc.Save(ctx, &models.ChangePlan{
+		TicketID:     1,
+		PlannedStart: start,
+		PlannedEnd:   end,
+		ServiceIDs:   []int{1, 1},
+		ConfigItems:  []string{" DB01.example.com ", "mx01"},
+	}, 5)
+	require.NoError(t, err)
+	assert.Equal(t, models.ChangeRiskMedium, saved.Risk)
+	assert.Equal(t, []int{1}, saved.ServiceIDs)
+	assert.ElementsMatch(t, []string{"DB01.example.com", "mx01"}, saved.ConfigItems)
+	require.Len(t, saved.Conflicts, 1)
+	assert.Equal(t, 2, saved.Conflicts[0].TicketID)
+	assert.Equal(t, models.ApprovalStatusApproved, saved.Conflicts[0].ApprovalStatus)
+	assert.Equal(t, []string{"db01.example.com"}, saved.Conflicts[0].ConfigItems)
+	assert.Empty(t, saved.Conflicts[0].ServiceIDs)
+	assert.Contains(t, (*history)[len(*history)-1], "conflicts with 1 other change(s)")
+
+	// Moving the window so it starts when the other change ends clears the conflict.
+	start, end = changeWindow(23, 26)
+	saved, err = svc.Save(ctx, &models.ChangePlan{TicketID: 1, PlannedStart: start, PlannedEnd: end,
+		ConfigItems: []string{"db01.example.com"}}, 5)
+	require.NoError(t, err)
+	assert.Empty(t, saved.Conflicts)
+	assert.Equal(t, []string{"db01.example.com"}, saved.ConfigItems, "items are replaced on save")
+}
+
+func TestChangeService_Calendar(t *testing.T) {
+	svc, _ := newChangeTestService(t)
+	ctx := context.Background()
+
+	start, end := changeWindow(20, 23)
+	for _, plan := range []models.ChangePlan{
+		{TicketID: 1, PlannedStart: start, PlannedEnd: end, ServiceIDs: []int{1}},
+		{TicketID: 2, PlannedStart: start.Add(time.Hour), PlannedEnd: end, ServiceIDs: []int{1}},
+		{TicketID: 4, PlannedStart: start.Add(48 * time.Hour), PlannedEnd: end.Add(48 * time.Hour), ServiceIDs: []int{2}},
+	} {
+		_, err := svc.Save(ctx, &plan, 1)
+		require.NoError(t, err)
+	}
+
+	from, to := changeWindow(0, 24)
+	plans, err := svc.Calendar(ctx, models.ChangeCalendarFilter{From: from, To: to, QueueIDs: []int{1}})
+	require.NoError(t, err)
+	require.Len(t, plans, 1, "ticket 2 is in a queue outside the filter and ticket 4 outside the range")
+	assert.Equal(t, 1, plans[0].TicketID)
+	require.Len(t, plans[0].Conflicts, 1, "conflicts include changes the filter leaves out")
+	assert.Equal(t, 2, plans[0].Conflicts[0].TicketID)
+
+	plans, err = svc.Calendar(ctx, models.ChangeCalendarFilter{From: from, To: to.Add(72 * time.Hour), ServiceID: 2})
+	require.NoError(t, err)
+	require.Len(t, plans, 1)
+	assert.Equal(t, 4, plans[0].TicketID)
+
+	_, err = svc.Calendar(ctx, models.ChangeCalendarFilter{From: to, To: from})
+	assert.ErrorIs(t, err, ErrChangeInvalidCalendar)
+
+	require.NoError(t, svc.Delete(ctx, 1, 1))
+	assert.ErrorIs(t, svc.Delete(ctx, 1, 1), ErrChangeNotFound)
+	_, err = svc.Get(ctx, 1)
+	assert.ErrorIs(t, err, ErrChangeNotFound)
+}
+
+func TestChangePlan_ConflictWith(t *testing.T) {
+	start, end := changeWindow(20, 22)
+	a := &models.ChangePlan{TicketID: 1, PlannedStart: start, PlannedEnd: end, ServiceIDs: []int{1, 2}, ConfigItems: []string{"Web01"}}
+	b := &models.ChangePlan{TicketID: 2, PlannedStart: start.Add(time.Hour), PlannedEnd: end.Add(time.Hour),
+		ServiceIDs: []int{2}, ConfigItems: []string{"web01 "}}
+
+	c := a.ConflictWith(b)
+	require.NotNil(t, c)
+	assert.Equal(t, []int{2}, c.ServiceIDs)
+	assert.Equal(t, []string{"web01 "}, c.ConfigItems)
+	assert.Nil(t, a.ConflictWith(a), "a change never conflicts with itself")
+
+	b.PlannedStart, b.PlannedEnd = end, end.Add(time.Hour)
+	assert.Nil(t, a.ConflictWith(b), "back-to-back windows do not overlap")
+}
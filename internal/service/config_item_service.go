@@ -0,0 +1,505 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/repository"
+)
+
+// Errors returned by ConfigItemService.
+var (
+	ErrConfigItemClassNotFound   = errors.New("config item class not found")
+	ErrConfigItemClassNameExists = errors.New("a config item class with this name already exists")
+	ErrConfigItemClassInUse      = errors.New("config item class still has items")
+	ErrConfigItemClassInvalid    = errors.New("invalid config item class")
+	ErrConfigItemNotFound        = errors.New("config item not found")
+	ErrConfigItemNumberExists    = errors.New("a config item with this number already exists")
+	ErrConfigItemInvalid         = errors.New("invalid config item")
+	ErrConfigItemTicketNotFound  = errors.New("ticket not found")
+	ErrConfigItemLinkNotFound    = errors.New("the ticket is not linked to this config item")
+)
+
+const (
+	// configItemMaxAttributes caps the attributes of one class.
+	configItemMaxAttributes = 50
+
+	// configItemMaxTextLength caps text attribute values.
+	configItemMaxTextLength = 2000
+
+	// ConfigItemDefaultLimit and ConfigItemMaxLimit bound search pages.
+	ConfigItemDefaultLimit = 50
+	ConfigItemMaxLimit     = 500
+)
+
+var configItemAttributeKey = regexp.MustCompile(`^[a-z][a-z0-9_]{0,49}$`)
+
+// ConfigItemService manages the CMDB: classes with their attribute
+// definitions, configuration items with their lifecycle states and
+// customers, and the tickets items are linked to.
+type ConfigItemService struct {
+	repo *repository.ConfigItemRepository
+	now  func() time.Time
+}
+
+// NewConfigItemService creates a config item service.
+func NewConfigItemService(db *sql.DB) *ConfigItemService {
+	return &ConfigItemService{repo: repository.NewConfigItemRepository(db), now: time.Now}
+}
+
+// ListClasses returns the classes; validOnly skips invalid ones.
+func (s *ConfigItemService) ListClasses(ctx context.Context, validOnly bool) ([]*models.ConfigItemClass, error) {
+	return s.repo.ListClasses(ctx, validOnly)
+}
+
+// GetClass returns a class.
+func (s *ConfigItemService) GetClass(ctx context.Context, id int) (*models.ConfigItemClass, error) {
+	class, err := s.repo.GetClass(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if class == nil {
+		return nil, ErrConfigItemClassNotFound
+	}
+	return class, nil
+}
+
+// CreateClass validates and stores a new class.
+func (s *ConfigItemService) CreateClass(ctx context.Context, class *models.ConfigItemClass, userID int) error {
+	if err := s.validateClass(ctx, class); err != nil {
+		return err
+	}
+	now := s.now()
+	class.CreateTime, class.CreateBy = now, userID
+	class.ChangeTime, class.ChangeBy = now, userID
+	return s.repo.CreateClass(ctx, class)
+}
+
+// UpdateClass validates and stores changes to a class. Values of removed
+// attributes stay on existing items until they are next updated.
+func (s *ConfigItemService) UpdateClass(ctx context.Context, class *models.ConfigItemClass, userID int) error {
+	existing, err := s.GetClass(ctx, class.ID)
+	if err != nil {
+		return err
+	}
+	if err := s.validateClass(ctx, class); err != nil {
+		return err
+	}
+	class.CreateTime, class.CreateBy = existing.CreateTime, existing.CreateBy
+	class.ChangeTime, class.ChangeBy = s.now(), userID
+	if err := s.repo.UpdateClass(ctx, class); err == sql.ErrNoRows {
+		return ErrConfigItemClassNotFound
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+// DeleteClass deletes a class without items. Classes in use can be set
+// invalid instead.
+func (s *ConfigItemService) DeleteClass(ctx context.Context, id int) error {
+	if _, err := s.GetClass(ctx, id); err != nil {
+		return err
+	}
+	if n, err := s.repo.CountClassItems(ctx, id); err != nil {
+		return err
+	} else if n > 0 {
+		return fmt.Errorf("%w: %d items", ErrConfigItemClassInUse, n)
+	}
+	deleted, err := s.repo.DeleteClass(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrConfigItemClassNotFound
+	}
+	return nil
+}
+
+// SearchItems returns a page of the items matching the filter and the
+// number of matching items.
+func (s *ConfigItemService) SearchItems(ctx context.Context, filter models.ConfigItemFilter) ([]*models.ConfigItem, int, error) {
+	if filter.State != "" {
+		if _, ok := models.ConfigItemStateTransitions[filter.State]; !ok {
+			return nil, 0, fmt.Errorf("%w: unknown state %q", ErrConfigItemInvalid, filter.State)
+		}
+	}
+	if filter.Limit <= 0 {
+		filter.Limit = ConfigItemDefaultLimit
+	}
+	if filter.Limit > ConfigItemMaxLimit {
+		filter.Limit = ConfigItemMaxLimit
+	}
+	if filter.Offset < 0 {
+		filter.Offset = 0
+	}
+	return s.repo.ListItems(ctx, filter)
+}
+
+// GetItem returns an item with its linked tickets.
+func (s *ConfigItemService) GetItem(ctx context.Context, id int64) (*models.ConfigItem, error) {
+	item, err := s.repo.GetItem(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if item == nil {
+		return nil, ErrConfigItemNotFound
+	}
+	return item, nil
+}
+
+// CreateItem validates and stores a new item. Items start planned unless
+// they set a state; items without a number are numbered automatically.
+func (s *ConfigItemService) CreateItem(ctx context.Context, item *models.ConfigItem, userID int) error {
+	if item.State == "" {
+		item.State = models.ConfigItemStatePlanned
+	}
+	if err := s.validateItem(ctx, item, nil); err != nil {
+		return err
+	}
+	now := s.now()
+	item.CreateTime, item.CreateBy = now, userID
+	item.ChangeTime, item.ChangeBy = now, userID
+	if err := s.repo.CreateItem(ctx, item); err != nil {
+		return err
+	}
+	item.Tickets = []models.ConfigItemTicket{}
+	return nil
+}
+
+// UpdateItem validates and stores changes to an item. The state may only
+// move along the lifecycle; an empty number or state keeps the current
+// one.
+func (s *ConfigItemService) UpdateItem(ctx context.Context, item *models.ConfigItem, userID int) error {
+	existing, err := s.GetItem(ctx, item.ID)
+	if err != nil {
+		return err
+	}
+	if item.Number == "" {
+		item.Number = existing.Number
+	}
+	if item.State == "" {
+		item.State = existing.State
+	}
+	if err := s.validateItem(ctx, item, existing); err != nil {
+		return err
+	}
+	item.CreateTime, item.CreateBy = existing.CreateTime, existing.CreateBy
+	item.ChangeTime, item.ChangeBy = s.now(), userID
+	if err := s.repo.UpdateItem(ctx, item); err == sql.ErrNoRows {
+		return ErrConfigItemNotFound
+	} else if err != nil {
+		return err
+	}
+	item.Tickets = existing.Tickets
+	return nil
+}
+
+// DeleteItem deletes an item with its ticket links.
+func (s *ConfigItemService) DeleteItem(ctx context.Context, id int64) error {
+	deleted, err := s.repo.DeleteItem(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrConfigItemNotFound
+	}
+	return nil
+}
+
+// LinkTicket links an item to a ticket, reporting whether the link is new.
+// Retired items cannot be linked.
+func (s *ConfigItemService) LinkTicket(ctx context.Context, itemID, ticketID int64, userID int) (bool, error) {
+	item, err := s.GetItem(ctx, itemID)
+	if err != nil {
+		return false, err
+	}
+	if ok, err := s.repo.TicketExists(ctx, ticketID); err != nil {
+		return false, err
+	} else if !ok {
+		return false, ErrConfigItemTicketNotFound
+	}
+	if item.State == models.ConfigItemStateRetired {
+		for _, t := range item.Tickets {
+			if t.ID == ticketID {
+				return false, nil
+			}
+		}
+		return false, fmt.Errorf("%w: retired items cannot be linked to tickets", ErrConfigItemInvalid)
+	}
+	return s.repo.LinkTicket(ctx, itemID, ticketID, userID, s.now())
+}
+
+// UnlinkTicket removes the link between an item and a ticket.
+func (s *ConfigItemService) UnlinkTicket(ctx context.Context, itemID, ticketID int64) error {
+	removed, err := s.repo.UnlinkTicket(ctx, itemID, ticketID)
+	if err != nil {
+		return err
+	}
+	if !removed {
+		return ErrConfigItemLinkNotFound
+	}
+	return nil
+}
+
+// TicketItems returns the items linked to a ticket.
+func (s *ConfigItemService) TicketItems(ctx context.Context, ticketID int64) ([]*models.ConfigItem, error) {
+	items, _, err := s.repo.ListItems(ctx, models.ConfigItemFilter{TicketID: ticketID})
+	return items, err
+}
+
+func (s *ConfigItemService) validateClass(ctx context.Context, class *models.ConfigItemClass) error {
+	class.Name = strings.TrimSpace(class.Name)
+	class.Description = strings.TrimSpace(class.Description)
+	switch {
+	case class.Name == "" || len(class.Name) > 200:
+		return fmt.Errorf("%w: name must be 1 to 200 characters", ErrConfigItemClassInvalid)
+	case len(class.Description) > 500:
+		return fmt.Errorf("%w: description must be at most 500 characters", ErrConfigItemClassInvalid)
+	case len(class.Attributes) > configItemMaxAttributes:
+		return fmt.Errorf("%w: at most %d attributes", ErrConfigItemClassInvalid, configItemMaxAttributes)
+	}
+	if class.ValidID == 0 {
+		class.ValidID = 1
+	}
+	if class.ValidID != 1 && class.ValidID != 2 {
+		return fmt.Errorf("%w: valid_id must be 1 or 2", ErrConfigItemClassInvalid)
+	}
+
+	if class.Attributes == nil {
+		class.Attributes = []models.ConfigItemAttribute{}
+	}
+	keys := make(map[string]bool, len(class.Attributes))
+	for i := range class.Attributes {
+		attr := &class.Attributes[i]
+		attr.Key = strings.TrimSpace(attr.Key)
+		attr.Label = strings.TrimSpace(attr.Label)
+		if !configItemAttributeKey.MatchString(attr.Key) {
+			return fmt.Errorf("%w: attribute key %q must be lower case letters, digits and underscores", ErrConfigItemClassInvalid, attr.Key)
+		}
+		if keys[attr.Key] {
+			return fmt.Errorf("%w: duplicate attribute %q", ErrConfigItemClassInvalid, attr.Key)
+		}
+		keys[attr.Key] = true
+		if attr.Label == "" {
+			attr.Label = attr.Key
+		}
+		switch attr.Type {
+		case "":
+			attr.Type = models.ConfigItemAttributeText
+		case models.ConfigItemAttributeText, models.ConfigItemAttributeNumber,
+			models.ConfigItemAttributeDate, models.ConfigItemAttributeBool, models.ConfigItemAttributeSelect:
+		default:
+			return fmt.Errorf("%w: attribute %q has unknown type %q", ErrConfigItemClassInvalid, attr.Key, attr.Type)
+		}
+		if attr.Type != models.ConfigItemAttributeSelect {
+			if len(attr.Options) > 0 {
+				return fmt.Errorf("%w: only select attributes have options", ErrConfigItemClassInvalid)
+			}
+			attr.Options = nil
+			continue
+		}
+		options := make([]string, 0, len(attr.Options))
+		seen := make(map[string]bool, len(attr.Options))
+		for _, option := range attr.Options {
+			option = strings.TrimSpace(option)
+			if option != "" && !seen[option] {
+				seen[option] = true
+				options = append(options, option)
+			}
+		}
+		if len(options) == 0 {
+			return fmt.Errorf("%w: select attribute %q needs options", ErrConfigItemClassInvalid, attr.Key)
+		}
+		attr.Options = options
+	}
+
+	if exists, err := s.repo.ClassNameExists(ctx, class.Name, class.ID); err != nil {
+		return err
+	} else if exists {
+		return ErrConfigItemClassNameExists
+	}
+	return nil
+}
+
+// validateItem checks and normalizes an item; existing is the stored item
+// on updates.
+func (s *ConfigItemService) validateItem(ctx context.Context, item *models.ConfigItem, existing *models.ConfigItem) error {
+	item.Name = strings.TrimSpace(item.Name)
+	item.Number = strings.TrimSpace(item.Number)
+	item.CustomerID = strings.TrimSpace(item.CustomerID)
+	item.CustomerUserID = strings.TrimSpace(item.CustomerUserID)
+	switch {
+	case item.Name == "" || len(item.Name) > 250:
+		return fmt.Errorf("%w: name must be 1 to 250 characters", ErrConfigItemInvalid)
+	case len(item.Number) > 100:
+		return fmt.Errorf("%w: number must be at most 100 characters", ErrConfigItemInvalid)
+	}
+	if _, ok := models.ConfigItemStateTransitions[item.State]; !ok {
+		return fmt.Errorf("%w: unknown state %q", ErrConfigItemInvalid, item.State)
+	}
+	if existing != nil && !models.ConfigItemStateAllowed(existing.State, item.State) {
+		return fmt.Errorf("%w: state cannot change from %s to %s", ErrConfigItemInvalid, existing.State, item.State)
+	}
+	if item.Number != "" {
+		var excludeID int64
+		if existing != nil {
+			excludeID = existing.ID
+		}
+		if exists, err := s.repo.NumberExists(ctx, item.Number, excludeID); err != nil {
+			return err
+		} else if exists {
+			return ErrConfigItemNumberExists
+		}
+	}
+
+	class, err := s.repo.GetClass(ctx, item.ClassID)
+	if err != nil {
+		return err
+	}
+	if class == nil {
+		return ErrConfigItemClassNotFound
+	}
+	if class.ValidID != 1 && (existing == nil || existing.ClassID != class.ID) {
+		return fmt.Errorf("%w: class %q is disabled", ErrConfigItemInvalid, class.Name)
+	}
+	item.ClassName = class.Name
+	var stale map[string]interface{}
+	if existing != nil && existing.ClassID == class.ID {
+		stale = existing.Attributes
+	}
+	attributes, err := normalizeConfigItemAttributes(class, item.Attributes, stale)
+	if err != nil {
+		return err
+	}
+	item.Attributes = attributes
+
+	if item.CustomerUserID != "" {
+		customerID, found, err := s.repo.CustomerUserCompany(ctx, item.CustomerUserID)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return fmt.Errorf("%w: unknown customer user %q", ErrConfigItemInvalid, item.CustomerUserID)
+		}
+		if item.CustomerID == "" {
+			item.CustomerID = customerID
+		} else if item.CustomerID != customerID {
+			return fmt.Errorf("%w: customer user %q belongs to customer %q", ErrConfigItemInvalid, item.CustomerUserID, customerID)
+		}
+	}
+	if item.CustomerID != "" {
+		if exists, err := s.repo.CustomerCompanyExists(ctx, item.CustomerID); err != nil {
+			return err
+		} else if !exists {
+			return fmt.Errorf("%w: unknown customer %q", ErrConfigItemInvalid, item.CustomerID)
+		}
+	}
+	return nil
+}
+
+// normalizeConfigItemAttributes checks attribute values against the class
+// and converts them to their stored form: strings for text, select and
+// date (YYYY-MM-DD) attributes, numbers and booleans. Keys the class no
+// longer defines are dropped when the item already had them (stale) and
+// rejected otherwise.
+func normalizeConfigItemAttributes(class *models.ConfigItemClass, values, stale map[string]interface{}) (map[string]interface{}, error) {
+	out := make(map[string]interface{}, len(values))
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value := values[key]
+		attr := class.Attribute(key)
+		if attr == nil {
+			if _, ok := stale[key]; ok {
+				continue
+			}
+			return nil, fmt.Errorf("%w: class %q has no attribute %q", ErrConfigItemInvalid, class.Name, key)
+		}
+		if value == nil {
+			continue
+		}
+		normalized, err := normalizeConfigItemValue(attr, value)
+		if err != nil {
+			return nil, err
+		}
+		if s, ok := normalized.(string); ok && s == "" {
+			continue
+		}
+		out[key] = normalized
+	}
+	for _, attr := range class.Attributes {
+		if _, ok := out[attr.Key]; attr.Required && !ok {
+			return nil, fmt.Errorf("%w: attribute %q is required", ErrConfigItemInvalid, attr.Key)
+		}
+	}
+	return out, nil
+}
+
+func normalizeConfigItemValue(attr *models.ConfigItemAttribute, value interface{}) (interface{}, error) {
+	invalid := func(want string) error {
+		return fmt.Errorf("%w: attribute %q must be %s", ErrConfigItemInvalid, attr.Key, want)
+	}
+	switch attr.Type {
+	case models.ConfigItemAttributeNumber:
+		switch v := value.(type) {
+		case float64:
+			return v, nil
+		case int:
+			return float64(v), nil
+		case string:
+			if strings.TrimSpace(v) == "" {
+				return "", nil
+			}
+			f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+			if err != nil {
+				return nil, invalid("a number")
+			}
+			return f, nil
+		}
+		return nil, invalid("a number")
+	case models.ConfigItemAttributeBool:
+		if v, ok := value.(bool); ok {
+			return v, nil
+		}
+		return nil, invalid("true or false")
+	}
+
+	v, ok := value.(string)
+	if !ok {
+		return nil, invalid("a string")
+	}
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return "", nil
+	}
+	switch attr.Type {
+	case models.ConfigItemAttributeDate:
+		if _, err := time.Parse("2006-01-02", v); err != nil {
+			return nil, invalid("a YYYY-MM-DD date")
+		}
+	case models.ConfigItemAttributeSelect:
+		for _, option := range attr.Options {
+			if option == v {
+				return v, nil
+			}
+		}
+		return nil, invalid("one of " + strings.Join(attr.Options, ", "))
+	default:
+		if len(v) > configItemMaxTextLength {
+			return nil, invalid(fmt.Sprintf("at most %d characters", configItemMaxTextLength))
+		}
+	}
+	return v, nil
+}
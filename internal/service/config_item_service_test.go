@@ -0,0 +1,149 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/testutil"
+)
+
+func TestConfigItemService(t *testing.T) {
+	db := testutil.UseMigratedDB(t)
+	_, err := db.Exec(`INSERT INTO users (id, login, pw, first_name, last_name, valid_id, create_time, create_by, change_time, change_by)
+		VALUES (1, 'root@localhost', 'x', 'Admin', 'OTRS', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`)
+	require.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO customer_company (customer_id, name, valid_id, create_time, create_by, change_time, change_by)
+		VALUES ('acme', 'Acme', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1),
+		       ('globex', 'Globex', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`)
+	require.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO customer_user (login, email, customer_id, first_name, last_name, valid_id,
+		create_time, create_by, change_time, change_by)
+		VALUES ('jane', 'jane@example.com', 'acme', 'Jane', 'Doe', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`)
+	require.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO ticket (id, tn, title, queue_id, ticket_lock_id, user_id, responsible_user_id,
+		ticket_priority_id, ticket_state_id, timeout, until_time, escalation_time, escalation_update_time,
+		escalation_response_time, escalation_solution_time, archive_flag, create_time, create_by, change_time, change_by)
+		VALUES (1, '2026031010000001', 'Laptop broken', 1, 1, 1, 1, 3, 1, 0, 0, 0, 0, 0, 0, 0,
+		        CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	s := NewConfigItemService(db)
+	s.now = func() time.Time { return time.Date(2026, 3, 10, 9, 0, 0, 0, time.UTC) }
+
+	for name, class := range map[string]*models.ConfigItemClass{
+		"no name":        {},
+		"bad key":        {Name: "Laptop", Attributes: []models.ConfigItemAttribute{{Key: "Serial No"}}},
+		"duplicate key":  {Name: "Laptop", Attributes: []models.ConfigItemAttribute{{Key: "serial"}, {Key: "serial"}}},
+		"unknown type":   {Name: "Laptop", Attributes: []models.ConfigItemAttribute{{Key: "serial", Type: "blob"}}},
+		"select options": {Name: "Laptop", Attributes: []models.ConfigItemAttribute{{Key: "os", Type: "select"}}},
+		"text options":   {Name: "Laptop", Attributes: []models.ConfigItemAttribute{{Key: "os", Options: []string{"Linux"}}}},
+		"bad valid id":   {Name: "Laptop", ValidID: 3},
+	} {
+		assert.ErrorIs(t, s.CreateClass(ctx, class, 1), ErrConfigItemClassInvalid, name)
+	}
+
+	class := &models.ConfigItemClass{Name: " Laptop ", Attributes: []models.ConfigItemAttribute{
+		{Key: "serial", Required: true},
+		{Key: "ram_gb", Type: "number"},
+		{Key: "purchased", Type: "date"},
+		{Key: "encrypted", Type: "bool"},
+		{Key: "os", Type: "select", Options: []string{"Linux", " macOS ", "Linux", ""}},
+	}}
+	require.NoError(t, s.CreateClass(ctx, class, 1))
+	assert.Equal(t, "Laptop", class.Name)
+	assert.Equal(t, 1, class.ValidID)
+	assert.Equal(t, "text", class.Attributes[0].Type)
+	assert.Equal(t, "serial", class.Attributes[0].Label)
+	assert.Equal(t, []string{"Linux", "macOS"}, class.Attributes[4].Options)
+	assert.ErrorIs(t, s.CreateClass(ctx, &models.ConfigItemClass{Name: "Laptop"}, 1), ErrConfigItemClassNameExists)
+
+	for name, tc := range map[string]struct {
+		item *models.ConfigItem
+		want error
+	}{
+		"no name":          {&models.ConfigItem{ClassID: class.ID, Attributes: map[string]interface{}{"serial": "1"}}, ErrConfigItemInvalid},
+		"unknown class":    {&models.ConfigItem{ClassID: 99, Name: "x"}, ErrConfigItemClassNotFound},
+		"unknown state":    {&models.ConfigItem{ClassID: class.ID, Name: "x", State: "lost"}, ErrConfigItemInvalid},
+		"missing required": {&models.ConfigItem{ClassID: class.ID, Name: "x"}, ErrConfigItemInvalid},
+		"unknown key":      {&models.ConfigItem{ClassID: class.ID, Name: "x", Attributes: map[string]interface{}{"serial": "1", "color": "red"}}, ErrConfigItemInvalid},
+		"bad number":       {&models.ConfigItem{ClassID: class.ID, Name: "x", Attributes: map[string]interface{}{"serial": "1", "ram_gb": "lots"}}, ErrConfigItemInvalid},
+		"bad date":         {&models.ConfigItem{ClassID: class.ID, Name: "x", Attributes: map[string]interface{}{"serial": "1", "purchased": "10.03.2026"}}, ErrConfigItemInvalid},
+		"bad bool":         {&models.ConfigItem{ClassID: class.ID, Name: "x", Attributes: map[string]interface{}{"serial": "1", "encrypted": "yes"}}, ErrConfigItemInvalid},
+		"bad option":       {&models.ConfigItem{ClassID: class.ID, Name: "x", Attributes: map[string]interface{}{"serial": "1", "os": "Windows"}}, ErrConfigItemInvalid},
+		"unknown customer": {&models.ConfigItem{ClassID: class.ID, Name: "x", CustomerID: "initech", Attributes: map[string]interface{}{"serial": "1"}}, ErrConfigItemInvalid},
+		"unknown user":     {&models.ConfigItem{ClassID: class.ID, Name: "x", CustomerUserID: "joe", Attributes: map[string]interface{}{"serial": "1"}}, ErrConfigItemInvalid},
+		"user of other":    {&models.ConfigItem{ClassID: class.ID, Name: "x", CustomerID: "globex", CustomerUserID: "jane", Attributes: map[string]interface{}{"serial": "1"}}, ErrConfigItemInvalid},
+	} {
+		assert.ErrorIs(t, s.CreateItem(ctx, tc.item, 1), tc.want, name)
+	}
+
+	item := &models.ConfigItem{ClassID: class.ID, Name: " Jane's laptop ", CustomerUserID: "jane",
+		Attributes: map[string]interface{}{"serial": " SN-4711 ", "ram_gb": "16", "purchased": "2026-01-15", "encrypted": true, "os": "macOS"}}
+	require.NoError(t, s.CreateItem(ctx, item, 1))
+	assert.Equal(t, "Jane's laptop", item.Name)
+	assert.Equal(t, "CI-000001", item.Number)
+	assert.Equal(t, models.ConfigItemStatePlanned, item.State)
+	assert.Equal(t, "acme", item.CustomerID, "the customer follows the customer user")
+	assert.Equal(t, map[string]interface{}{"serial": "SN-4711", "ram_gb": 16.0, "purchased": "2026-01-15", "encrypted": true, "os": "macOS"}, item.Attributes)
+	assert.ErrorIs(t, s.CreateItem(ctx, &models.ConfigItem{ClassID: class.ID, Name: "x", Number: "CI-000001",
+		Attributes: map[string]interface{}{"serial": "1"}}, 1), ErrConfigItemNumberExists)
+
+	// Removed attributes are dropped from items that had them
+	class.Attributes = class.Attributes[:4]
+	require.NoError(t, s.UpdateClass(ctx, class, 1))
+	loaded, err := s.GetItem(ctx, item.ID)
+	require.NoError(t, err)
+	loaded.Number, loaded.State = "", models.ConfigItemStateInUse
+	require.NoError(t, s.UpdateItem(ctx, loaded, 1))
+	assert.Equal(t, "CI-000001", loaded.Number)
+	assert.NotContains(t, loaded.Attributes, "os")
+
+	linked, err := s.LinkTicket(ctx, item.ID, 1, 1)
+	require.NoError(t, err)
+	assert.True(t, linked)
+	_, err = s.LinkTicket(ctx, item.ID, 2, 1)
+	assert.ErrorIs(t, err, ErrConfigItemTicketNotFound)
+	items, err := s.TicketItems(ctx, 1)
+	require.NoError(t, err)
+	require.Len(t, items, 1)
+	assert.Equal(t, item.ID, items[0].ID)
+
+	loaded.State = models.ConfigItemStateRetired
+	require.NoError(t, s.UpdateItem(ctx, loaded, 1))
+	loaded.State = models.ConfigItemStateInUse
+	assert.ErrorIs(t, s.UpdateItem(ctx, loaded, 1), ErrConfigItemInvalid, "retired items stay retired")
+	linked, err = s.LinkTicket(ctx, item.ID, 1, 1)
+	require.NoError(t, err)
+	assert.False(t, linked, "existing links of retired items are kept")
+	require.NoError(t, s.UnlinkTicket(ctx, item.ID, 1))
+	_, err = s.LinkTicket(ctx, item.ID, 1, 1)
+	assert.ErrorIs(t, err, ErrConfigItemInvalid, "retired items cannot be linked")
+	assert.ErrorIs(t, s.UnlinkTicket(ctx, item.ID, 1), ErrConfigItemLinkNotFound)
+
+	_, _, err = s.SearchItems(ctx, models.ConfigItemFilter{State: "lost"})
+	assert.ErrorIs(t, err, ErrConfigItemInvalid)
+	found, total, err := s.SearchItems(ctx, models.ConfigItemFilter{Query: "sn-4711"})
+	require.NoError(t, err)
+	assert.Equal(t, 1, total)
+	require.Len(t, found, 1)
+
+	assert.ErrorIs(t, s.DeleteClass(ctx, class.ID), ErrConfigItemClassInUse)
+	require.NoError(t, s.DeleteItem(ctx, item.ID))
+	assert.ErrorIs(t, s.DeleteItem(ctx, item.ID), ErrConfigItemNotFound)
+	require.NoError(t, s.DeleteClass(ctx, class.ID))
+	assert.ErrorIs(t, s.DeleteClass(ctx, class.ID), ErrConfigItemClassNotFound)
+}
+
+func TestConfigItemStateAllowed(t *testing.T) {
+	assert.True(t, models.ConfigItemStateAllowed(models.ConfigItemStatePlanned, models.ConfigItemStateInUse))
+	assert.True(t, models.ConfigItemStateAllowed(models.ConfigItemStateInUse, models.ConfigItemStateInUse))
+	assert.False(t, models.ConfigItemStateAllowed(models.ConfigItemStateInUse, models.ConfigItemStatePlanned))
+	assert.False(t, models.ConfigItemStateAllowed(models.ConfigItemStateRetired, models.ConfigItemStateInStock))
+	assert.False(t, models.ConfigItemStateAllowed("lost", "lost"))
+}
@@ -0,0 +1,178 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/repository"
+)
+
+// Errors returned by CustomerCompanyTreeService.
+var (
+	ErrCustomerCompanyNotFound       = errors.New("customer company not found")
+	ErrCustomerCompanyParentNotFound = errors.New("parent_customer_id must be an existing customer company")
+	ErrCustomerCompanyCycle          = errors.New("a company cannot be placed below itself or one of its subsidiaries")
+	ErrCustomerCompanyTooDeep        = fmt.Errorf("the company hierarchy would be deeper than %d levels", models.MaxCustomerCompanyDepth)
+)
+
+// CustomerCompanyTreeService manages the customer company hierarchy.
+// PermissionService walks the same parent links when it checks customer
+// access.
+type CustomerCompanyTreeService struct {
+	repo *repository.CustomerCompanyTreeRepository
+}
+
+// NewCustomerCompanyTreeService creates a customer company tree service.
+func NewCustomerCompanyTreeService(db *sql.DB) *CustomerCompanyTreeService {
+	return &CustomerCompanyTreeService{repo: repository.NewCustomerCompanyTreeRepository(db)}
+}
+
+// companyTree is every company indexed by ID, with children linked.
+type companyTree struct {
+	nodes map[string]*models.CustomerCompanyNode
+	roots []*models.CustomerCompanyNode
+}
+
+func (s *CustomerCompanyTreeService) load(ctx context.Context) (*companyTree, error) {
+	nodes, err := s.repo.ListNodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	tree := &companyTree{nodes: make(map[string]*models.CustomerCompanyNode, len(nodes))}
+	for _, n := range nodes {
+		tree.nodes[n.CustomerID] = n
+	}
+	// A parent link to a missing company leaves the company at the top.
+	for _, n := range nodes {
+		parent := tree.nodes[n.ParentCustomerID]
+		if parent == nil || tree.cyclic(n) {
+			tree.roots = append(tree.roots, n)
+			continue
+		}
+		parent.Children = append(parent.Children, n)
+	}
+	return tree, nil
+}
+
+// cyclic reports whether n's parent links lead back to n, which only
+// hand-edited data can do. Such companies are shown at the top level.
+func (t *companyTree) cyclic(n *models.CustomerCompanyNode) bool {
+	p := t.nodes[n.ParentCustomerID]
+	for steps := 0; p != nil && steps < len(t.nodes); steps++ {
+		if p == n {
+			return true
+		}
+		p = t.nodes[p.ParentCustomerID]
+	}
+	return false
+}
+
+// ancestors returns the companies above id, parent first, stopping at a
+// cycle.
+func (t *companyTree) ancestors(id string) []*models.CustomerCompanyNode {
+	var result []*models.CustomerCompanyNode
+	seen := map[string]bool{id: true}
+	for n := t.nodes[id]; n != nil; {
+		parent := t.nodes[n.ParentCustomerID]
+		if parent == nil || seen[parent.CustomerID] {
+			break
+		}
+		seen[parent.CustomerID] = true
+		result = append(result, parent)
+		n = parent
+	}
+	return result
+}
+
+// height is the number of levels in the subtree of n, n included.
+func height(n *models.CustomerCompanyNode) int {
+	h := 0
+	for _, child := range n.Children {
+		if ch := height(child); ch > h {
+			h = ch
+		}
+	}
+	return h + 1
+}
+
+// Tree returns the top-level companies with their subsidiaries nested in
+// Children.
+func (s *CustomerCompanyTreeService) Tree(ctx context.Context) ([]*models.CustomerCompanyNode, error) {
+	tree, err := s.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if tree.roots == nil {
+		return []*models.CustomerCompanyNode{}, nil
+	}
+	return tree.roots, nil
+}
+
+// Hierarchy returns the companies above customerID and its subtree.
+func (s *CustomerCompanyTreeService) Hierarchy(ctx context.Context, customerID string) (*models.CustomerCompanyHierarchy, error) {
+	tree, err := s.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return tree.hierarchy(customerID)
+}
+
+func (t *companyTree) hierarchy(customerID string) (*models.CustomerCompanyHierarchy, error) {
+	company := t.nodes[customerID]
+	if company == nil {
+		return nil, ErrCustomerCompanyNotFound
+	}
+	h := &models.CustomerCompanyHierarchy{Ancestors: []models.CustomerCompanyNode{}, Company: company}
+	for _, a := range t.ancestors(customerID) {
+		node := *a
+		node.Children = nil
+		h.Ancestors = append(h.Ancestors, node)
+	}
+	return h, nil
+}
+
+// SetParent moves customerID below parentID, or to the top level when
+// parentID is empty, and returns the company's new place in the hierarchy.
+// The company's subtree moves with it.
+func (s *CustomerCompanyTreeService) SetParent(ctx context.Context, customerID, parentID string, userID int) (*models.CustomerCompanyHierarchy, error) {
+	parentID = strings.TrimSpace(parentID)
+	tree, err := s.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	company := tree.nodes[customerID]
+	if company == nil {
+		return nil, ErrCustomerCompanyNotFound
+	}
+	if parentID != "" {
+		if tree.nodes[parentID] == nil {
+			return nil, ErrCustomerCompanyParentNotFound
+		}
+		if parentID == customerID {
+			return nil, ErrCustomerCompanyCycle
+		}
+		above := tree.ancestors(parentID)
+		for _, a := range above {
+			if a.CustomerID == customerID {
+				return nil, ErrCustomerCompanyCycle
+			}
+		}
+		// The parent's level plus the levels the moved subtree brings along.
+		if len(above)+1+height(company) > models.MaxCustomerCompanyDepth {
+			return nil, ErrCustomerCompanyTooDeep
+		}
+	}
+
+	ok, err := s.repo.SetParent(ctx, customerID, parentID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrCustomerCompanyNotFound
+	}
+	return s.Hierarchy(ctx, customerID)
+}
@@ -0,0 +1,134 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/testutil"
+)
+
+func newCustomerCompanyTreeTestService(t *testing.T) (*CustomerCompanyTreeService, *sql.DB) {
+	t.Helper()
+	db := testutil.MigratedDB(t)
+	for _, c := range []struct {
+		id, name string
+		parent   interface{}
+		validID  int
+	}{
+		{"acme", "Acme Holding", nil, 1},
+		{"acme-eu", "Acme Europe", "acme", 1},
+		{"acme-de", "Acme Germany", "acme-eu", 2},
+		{"globex", "Globex", nil, 1},
+		{"orphan", "Orphan Ltd", "gone", 1},
+	} {
+		insertTreeTestCompany(t, db, c.id, c.name, c.parent, c.validID)
+	}
+	return NewCustomerCompanyTreeService(db), db
+}
+
+func insertTreeTestCompany(t *testing.T, db *sql.DB, id, name string, parent interface{}, validID int) {
+	t.Helper()
+	_, err := db.Exec(`INSERT INTO customer_company (customer_id, name, parent_customer_id, valid_id,
+		create_time, create_by, change_time, change_by) VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+		id, name, parent, validID)
+	require.NoError(t, err)
+}
+
+func TestCustomerCompanyTreeService_Tree(t *testing.T) {
+	svc, _ := newCustomerCompanyTreeTestService(t)
+
+	roots, err := svc.Tree(context.Background())
+	require.NoError(t, err)
+	require.Len(t, roots, 3)
+	assert.Equal(t, "acme", roots[0].CustomerID)
+	assert.Equal(t, "globex", roots[1].CustomerID)
+	assert.Equal(t, "orphan", roots[2].CustomerID, "a missing parent leaves the company at the top")
+
+	require.Len(t, roots[0].Children, 1)
+	eu := roots[0].Children[0]
+	assert.Equal(t, "acme-eu", eu.CustomerID)
+	require.Len(t, eu.Children, 1)
+	assert.Equal(t, "acme-de", eu.Children[0].CustomerID)
+	assert.Equal(t, 2, eu.Children[0].ValidID)
+}
+
+func TestCustomerCompanyTreeService_Hierarchy(t *testing.T) {
+	svc, _ := newCustomerCompanyTreeTestService(t)
+	ctx := context.Background()
+
+	h, err := svc.Hierarchy(ctx, "acme-de")
+	require.NoError(t, err)
+	require.Len(t, h.Ancestors, 2)
+	assert.Equal(t, "acme-eu", h.Ancestors[0].CustomerID)
+	assert.Equal(t, "acme", h.Ancestors[1].CustomerID)
+	assert.Nil(t, h.Ancestors[1].Children)
+	assert.Equal(t, "acme-de", h.Company.CustomerID)
+
+	_, err = svc.Hierarchy(ctx, "missing")
+	assert.ErrorIs(t, err, ErrCustomerCompanyNotFound)
+}
+
+func TestCustomerCompanyTreeService_SetParent(t *testing.T) {
+	svc, db := newCustomerCompanyTreeTestService(t)
+	ctx := context.Background()
+
+	h, err := svc.SetParent(ctx, "acme-eu", "globex", 7)
+	require.NoError(t, err)
+	require.Len(t, h.Ancestors, 1)
+	assert.Equal(t, "globex", h.Ancestors[0].CustomerID)
+	require.Len(t, h.Company.Children, 1, "the subtree moves along")
+
+	var changeBy int
+	require.NoError(t, db.QueryRow(`SELECT change_by FROM customer_company WHERE customer_id = 'acme-eu'`).Scan(&changeBy))
+	assert.Equal(t, 7, changeBy)
+
+	h, err = svc.SetParent(ctx, "acme-eu", " ", 7)
+	require.NoError(t, err)
+	assert.Empty(t, h.Ancestors)
+	var parent sql.NullString
+	require.NoError(t, db.QueryRow(`SELECT parent_customer_id FROM customer_company WHERE customer_id = 'acme-eu'`).Scan(&parent))
+	assert.False(t, parent.Valid)
+
+	_, err = svc.SetParent(ctx, "missing", "acme", 7)
+	assert.ErrorIs(t, err, ErrCustomerCompanyNotFound)
+	_, err = svc.SetParent(ctx, "acme", "missing", 7)
+	assert.ErrorIs(t, err, ErrCustomerCompanyParentNotFound)
+}
+
+func TestCustomerCompanyTreeService_SetParentRejectsCycles(t *testing.T) {
+	svc, _ := newCustomerCompanyTreeTestService(t)
+	ctx := context.Background()
+
+	_, err := svc.SetParent(ctx, "acme", "acme", 1)
+	assert.ErrorIs(t, err, ErrCustomerCompanyCycle)
+	_, err = svc.SetParent(ctx, "acme-eu", "acme-de", 1)
+	assert.ErrorIs(t, err, ErrCustomerCompanyCycle)
+}
+
+func TestCustomerCompanyTreeService_SetParentLimitsDepth(t *testing.T) {
+	svc, db := newCustomerCompanyTreeTestService(t)
+	ctx := context.Background()
+
+	// acme, acme-eu, acme-de and chain-1..chain-6 make nine levels.
+	parent := "acme-de"
+	for i := 1; i <= 6; i++ {
+		id := fmt.Sprintf("chain-%d", i)
+		insertTreeTestCompany(t, db, id, id, parent, 1)
+		parent = id
+	}
+
+	_, err := svc.SetParent(ctx, "globex", "chain-6", 1)
+	require.NoError(t, err, "the tenth level is allowed")
+
+	// Moving acme below orphan would push globex to the eleventh level.
+	_, err = svc.SetParent(ctx, "acme", "orphan", 1)
+	assert.ErrorIs(t, err, ErrCustomerCompanyTooDeep)
+
+	_, err = svc.SetParent(ctx, "chain-6", "orphan", 1)
+	require.NoError(t, err, "a shallower subtree fits")
+}
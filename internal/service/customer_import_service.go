@@ -0,0 +1,498 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/mail"
+	"strconv"
+	"strings"
+
+	"github.com/goatkit/goatflow/internal/auth"
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/repository"
+	"github.com/goatkit/goatflow/internal/spreadsheet"
+)
+
+// Errors returned by CustomerImportService.
+var (
+	ErrCustomerImportKind    = errors.New("import kind must be users or companies")
+	ErrCustomerImportFile    = errors.New("invalid import file")
+	ErrCustomerImportMapping = errors.New("invalid column mapping")
+)
+
+// MaxCustomerImportRows is the most data rows one import file may have.
+const MaxCustomerImportRows = 10000
+
+// customerImportFields lists the fields of each import kind, key first.
+var customerImportFields = map[models.CustomerImportKind][]models.CustomerImportField{
+	models.CustomerImportUsers: {
+		{Name: "login", Label: "Login", Required: true},
+		{Name: "email", Label: "Email", Required: true},
+		{Name: "customer_id", Label: "Customer ID", Required: true},
+		{Name: "password", Label: "Password"},
+		{Name: "title", Label: "Title"},
+		{Name: "first_name", Label: "First name"},
+		{Name: "last_name", Label: "Last name"},
+		{Name: "phone", Label: "Phone"},
+		{Name: "fax", Label: "Fax"},
+		{Name: "mobile", Label: "Mobile"},
+		{Name: "street", Label: "Street"},
+		{Name: "zip", Label: "ZIP"},
+		{Name: "city", Label: "City"},
+		{Name: "country", Label: "Country"},
+		{Name: "comments", Label: "Comments"},
+		{Name: "valid_id", Label: "Validity"},
+	},
+	models.CustomerImportCompanies: {
+		{Name: "customer_id", Label: "Customer ID", Required: true},
+		{Name: "name", Label: "Name", Required: true},
+		{Name: "street", Label: "Street"},
+		{Name: "zip", Label: "ZIP"},
+		{Name: "city", Label: "City"},
+		{Name: "country", Label: "Country"},
+		{Name: "url", Label: "URL"},
+		{Name: "comments", Label: "Comments"},
+		{Name: "valid_id", Label: "Validity"},
+	},
+}
+
+// customerImportAliases maps normalized header names other than a field's
+// name and label to the field, covering OTRS export headers.
+var customerImportAliases = map[models.CustomerImportKind]map[string]string{
+	models.CustomerImportUsers: {
+		"userlogin": "login", "username": "login", "user": "login",
+		"useremail": "email", "mail": "email", "emailaddress": "email",
+		"usercustomerid": "customer_id", "company": "customer_id", "companyid": "customer_id",
+		"userpassword": "password", "pw": "password",
+		"usertitle": "title", "salutation": "title",
+		"userfirstname": "first_name", "firstname": "first_name", "givenname": "first_name",
+		"userlastname": "last_name", "lastname": "last_name", "surname": "last_name",
+		"userphone": "phone", "telephone": "phone", "userfax": "fax", "usermobile": "mobile",
+		"userstreet": "street", "userzip": "zip", "postcode": "zip", "postalcode": "zip",
+		"usercity": "city", "usercountry": "country", "usercomment": "comments", "comment": "comments",
+		"valid": "valid_id", "validid": "valid_id",
+	},
+	models.CustomerImportCompanies: {
+		"customercompanyid": "customer_id", "companyid": "customer_id",
+		"customercompanyname": "name", "company": "name", "companyname": "name",
+		"customercompanystreet": "street", "customercompanyzip": "zip", "postcode": "zip", "postalcode": "zip",
+		"customercompanycity": "city", "customercompanycountry": "country",
+		"customercompanyurl": "url", "website": "url",
+		"customercompanycomment": "comments", "comment": "comments",
+		"valid": "valid_id", "validid": "valid_id",
+	},
+}
+
+// customerImportValidity maps the accepted validity values to valid IDs.
+var customerImportValidity = map[string]int{
+	"1": 1, "valid": 1,
+	"2": 2, "invalid": 2,
+	"3": 3, "invalid-temporarily": 3,
+}
+
+// CustomerImportOptions controls how an import file is read and applied.
+type CustomerImportOptions struct {
+	// Mapping replaces the mapping detected from the header row with one
+	// field name per column; an empty name leaves the column out.
+	Mapping []string
+	// UpdateExisting updates records that already exist instead of
+	// skipping them.
+	UpdateExisting bool
+}
+
+// CustomerImportService imports customer users and customer companies from
+// CSV and Excel files. A file is previewed first: its columns are mapped to
+// fields and every row is validated and given an action. Importing applies
+// the valid rows in one transaction.
+type CustomerImportService struct {
+	repo   *repository.CustomerImportRepository
+	hasher *auth.PasswordHasher
+}
+
+// NewCustomerImportService creates a customer import service.
+func NewCustomerImportService(db *sql.DB) *CustomerImportService {
+	return &CustomerImportService{
+		repo:   repository.NewCustomerImportRepository(db),
+		hasher: auth.NewPasswordHasher(),
+	}
+}
+
+// CustomerImportFields returns the fields file columns can be mapped to.
+func CustomerImportFields(kind models.CustomerImportKind) ([]models.CustomerImportField, error) {
+	fields, ok := customerImportFields[kind]
+	if !ok {
+		return nil, ErrCustomerImportKind
+	}
+	return fields, nil
+}
+
+// plannedImport is a preview together with the cells left out of it.
+type plannedImport struct {
+	preview *models.CustomerImportPreview
+	// passwords holds each row's password cell, by row index.
+	passwords []string
+}
+
+// Preview reads an import file and reports what importing it would do.
+func (s *CustomerImportService) Preview(ctx context.Context, kind models.CustomerImportKind, filename string, data []byte, opts CustomerImportOptions) (*models.CustomerImportPreview, error) {
+	plan, err := s.plan(ctx, kind, filename, data, opts)
+	if err != nil {
+		return nil, err
+	}
+	return plan.preview, nil
+}
+
+// Import applies the valid rows of an import file. Either every valid row
+// is written or, when the database refuses one, none is.
+func (s *CustomerImportService) Import(ctx context.Context, kind models.CustomerImportKind, filename string, data []byte, opts CustomerImportOptions, userID int) (*models.CustomerImportResult, error) {
+	plan, err := s.plan(ctx, kind, filename, data, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.CustomerImportResult{Kind: kind, Rows: plan.preview.Rows}
+	var records []repository.CustomerImportRecord
+	for i, row := range plan.preview.Rows {
+		switch row.Action {
+		case models.CustomerImportCreate, models.CustomerImportUpdate:
+			rec, err := s.record(kind, row, plan.passwords[i])
+			if err != nil {
+				return nil, err
+			}
+			records = append(records, rec)
+		}
+	}
+	if err := s.repo.Apply(ctx, kind, records, userID); err != nil {
+		return nil, err
+	}
+
+	result.Created = plan.preview.Creates
+	result.Updated = plan.preview.Updates
+	result.Skipped = plan.preview.Skips
+	result.Failed = plan.preview.Invalid
+	return result, nil
+}
+
+// record converts a validated row to the columns it writes.
+func (s *CustomerImportService) record(kind models.CustomerImportKind, row models.CustomerImportRow, password string) (repository.CustomerImportRecord, error) {
+	rec := repository.CustomerImportRecord{
+		Create:  row.Action == models.CustomerImportCreate,
+		Columns: make(map[string]interface{}, len(row.Values)+1),
+	}
+	for name, value := range row.Values {
+		rec.Columns[name] = value
+	}
+	if kind == models.CustomerImportUsers {
+		rec.Columns["login"] = row.Key
+	} else {
+		rec.Columns["customer_id"] = row.Key
+	}
+	if v, ok := row.Values["valid_id"]; ok {
+		rec.Columns["valid_id"] = customerImportValidity[v]
+	}
+	// An empty password cell keeps the current password.
+	if password != "" {
+		hash, err := s.hasher.HashPassword(password)
+		if err != nil {
+			return rec, fmt.Errorf("hash password of %s: %w", row.Key, err)
+		}
+		rec.Columns["pw"] = hash
+	}
+	return rec, nil
+}
+
+func (s *CustomerImportService) plan(ctx context.Context, kind models.CustomerImportKind, filename string, data []byte, opts CustomerImportOptions) (*plannedImport, error) {
+	fields, err := CustomerImportFields(kind)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := spreadsheet.Read(filename, data, 0)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCustomerImportFile, err)
+	}
+	if len(rows) < 2 {
+		return nil, fmt.Errorf("%w: the file needs a header row and at least one data row", ErrCustomerImportFile)
+	}
+	if len(rows)-1 > MaxCustomerImportRows {
+		return nil, fmt.Errorf("%w: the file has more than %d data rows", ErrCustomerImportFile, MaxCustomerImportRows)
+	}
+
+	headers := make([]string, len(rows[0]))
+	for i, h := range rows[0] {
+		headers[i] = strings.TrimSpace(h)
+	}
+	mapping := opts.Mapping
+	if mapping == nil {
+		mapping = DetectCustomerImportMapping(kind, headers)
+	} else if mapping, err = checkCustomerImportMapping(fields, headers, mapping); err != nil {
+		return nil, err
+	}
+
+	preview := &models.CustomerImportPreview{Kind: kind, Fields: fields, Headers: headers, Mapping: mapping}
+	plan := &plannedImport{preview: preview}
+	v, err := s.newCustomerImportValidator(ctx, kind, opts.UpdateExisting)
+	if err != nil {
+		return nil, err
+	}
+	for i, cells := range rows[1:] {
+		values := make(map[string]string)
+		blank := true
+		for col, name := range mapping {
+			if name == "" {
+				continue
+			}
+			var cell string
+			if col < len(cells) {
+				cell = strings.TrimSpace(cells[col])
+			}
+			if cell != "" {
+				blank = false
+			}
+			values[name] = cell
+		}
+		// Spreadsheets often carry empty rows below the data.
+		if blank {
+			continue
+		}
+
+		password := values["password"]
+		delete(values, "password")
+		row := v.check(i+2, values)
+		switch row.Action {
+		case models.CustomerImportCreate:
+			preview.Creates++
+		case models.CustomerImportUpdate:
+			preview.Updates++
+		case models.CustomerImportSkip:
+			preview.Skips++
+		default:
+			preview.Invalid++
+		}
+		preview.Rows = append(preview.Rows, row)
+		plan.passwords = append(plan.passwords, password)
+	}
+	return plan, nil
+}
+
+// DetectCustomerImportMapping maps each header to the field whose name,
+// label or known alias it matches, ignoring case, spaces, dashes and
+// underscores. Unknown and repeated headers are left out.
+func DetectCustomerImportMapping(kind models.CustomerImportKind, headers []string) []string {
+	lookup := make(map[string]string)
+	for _, f := range customerImportFields[kind] {
+		lookup[normalizeImportHeader(f.Name)] = f.Name
+		lookup[normalizeImportHeader(f.Label)] = f.Name
+	}
+	for alias, name := range customerImportAliases[kind] {
+		lookup[alias] = name
+	}
+
+	mapping := make([]string, len(headers))
+	used := make(map[string]bool)
+	for i, h := range headers {
+		if name := lookup[normalizeImportHeader(h)]; name != "" && !used[name] {
+			mapping[i] = name
+			used[name] = true
+		}
+	}
+	return mapping
+}
+
+func normalizeImportHeader(h string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '-', '_', '.':
+			return -1
+		}
+		return r
+	}, strings.ToLower(strings.TrimSpace(h)))
+}
+
+// checkCustomerImportMapping validates a mapping given for the headers,
+// padding it to one entry per column.
+func checkCustomerImportMapping(fields []models.CustomerImportField, headers, mapping []string) ([]string, error) {
+	if len(mapping) > len(headers) {
+		return nil, fmt.Errorf("%w: %d columns mapped but the file has %d", ErrCustomerImportMapping, len(mapping), len(headers))
+	}
+	known := make(map[string]bool, len(fields))
+	for _, f := range fields {
+		known[f.Name] = true
+	}
+	result := make([]string, len(headers))
+	used := make(map[string]bool)
+	for i, name := range mapping {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if !known[name] {
+			return nil, fmt.Errorf("%w: unknown field %q", ErrCustomerImportMapping, name)
+		}
+		if used[name] {
+			return nil, fmt.Errorf("%w: field %q is mapped more than once", ErrCustomerImportMapping, name)
+		}
+		used[name] = true
+		result[i] = name
+	}
+	return result, nil
+}
+
+// customerImportValidator checks rows against the existing records and the
+// rows before them.
+type customerImportValidator struct {
+	kind           models.CustomerImportKind
+	fields         []models.CustomerImportField
+	updateExisting bool
+	// existing maps lower-cased keys to the stored key.
+	existing map[string]string
+	// companies holds customer company names by customer ID.
+	companies map[string]string
+	// seen maps lower-cased keys, and company names, to their first line.
+	seen      map[string]int
+	seenNames map[string]int
+}
+
+func (s *CustomerImportService) newCustomerImportValidator(ctx context.Context, kind models.CustomerImportKind, updateExisting bool) (*customerImportValidator, error) {
+	companies, err := s.repo.CustomerCompanyNames(ctx)
+	if err != nil {
+		return nil, err
+	}
+	v := &customerImportValidator{
+		kind:           kind,
+		fields:         customerImportFields[kind],
+		updateExisting: updateExisting,
+		existing:       make(map[string]string),
+		companies:      companies,
+		seen:           make(map[string]int),
+		seenNames:      make(map[string]int),
+	}
+	if kind == models.CustomerImportUsers {
+		logins, err := s.repo.CustomerUserLogins(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for login := range logins {
+			v.existing[strings.ToLower(login)] = login
+		}
+	} else {
+		for id := range companies {
+			v.existing[strings.ToLower(id)] = id
+		}
+	}
+	return v, nil
+}
+
+func (v *customerImportValidator) check(line int, values map[string]string) models.CustomerImportRow {
+	keyField := v.fields[0].Name
+	row := models.CustomerImportRow{Line: line, Key: values[keyField], Values: values}
+	delete(values, keyField)
+
+	for _, f := range v.fields {
+		if !f.Required {
+			continue
+		}
+		value := values[f.Name]
+		if f.Name == keyField {
+			value = row.Key
+		}
+		if value == "" {
+			row.Errors = append(row.Errors, f.Label+" is required")
+		}
+	}
+	if vid, ok := values["valid_id"]; ok {
+		if vid == "" {
+			delete(values, "valid_id")
+		} else if id, ok := customerImportValidity[strings.ToLower(vid)]; ok {
+			values["valid_id"] = strconv.Itoa(id)
+		} else {
+			row.Errors = append(row.Errors, fmt.Sprintf("Validity %q must be valid, invalid or invalid-temporarily", vid))
+		}
+	}
+
+	lowerKey := strings.ToLower(row.Key)
+	if row.Key != "" {
+		if first, ok := v.seen[lowerKey]; ok {
+			row.Errors = append(row.Errors, fmt.Sprintf("%s %s already appears on line %d", v.fields[0].Label, row.Key, first))
+		} else {
+			v.seen[lowerKey] = line
+		}
+	}
+	stored, exists := v.existing[lowerKey]
+	if exists {
+		row.Key = stored
+	}
+
+	if v.kind == models.CustomerImportUsers {
+		v.checkUser(&row)
+	} else {
+		v.checkCompany(&row)
+	}
+
+	switch {
+	case len(row.Errors) > 0:
+		row.Action = models.CustomerImportError
+	case !exists:
+		row.Action = models.CustomerImportCreate
+	case v.updateExisting:
+		row.Action = models.CustomerImportUpdate
+	default:
+		row.Action = models.CustomerImportSkip
+		row.Warnings = append(row.Warnings, "Already exists")
+	}
+	return row
+}
+
+func (v *customerImportValidator) checkUser(row *models.CustomerImportRow) {
+	if email := row.Values["email"]; email != "" {
+		if addr, err := mail.ParseAddress(email); err != nil || addr.Address != email {
+			row.Errors = append(row.Errors, fmt.Sprintf("Email %q is not a valid address", email))
+		}
+	}
+	// Customer users may belong to a customer ID without a company record,
+	// but it is usually a typo or companies imported after their users.
+	if id := row.Values["customer_id"]; id != "" {
+		if _, ok := v.companies[id]; !ok {
+			row.Warnings = append(row.Warnings, fmt.Sprintf("Customer company %s does not exist", id))
+		}
+	}
+}
+
+func (v *customerImportValidator) checkCompany(row *models.CustomerImportRow) {
+	name := row.Values["name"]
+	if name == "" {
+		return
+	}
+	lower := strings.ToLower(name)
+	if first, ok := v.seenNames[lower]; ok {
+		row.Errors = append(row.Errors, fmt.Sprintf("Name %s already appears on line %d", name, first))
+		return
+	}
+	v.seenNames[lower] = row.Line
+	for id, existing := range v.companies {
+		if strings.EqualFold(existing, name) && !strings.EqualFold(id, row.Key) {
+			row.Errors = append(row.Errors, fmt.Sprintf("Name %s is already used by customer company %s", name, id))
+			return
+		}
+	}
+}
+
+// WriteCustomerImportReport writes the outcome of every row as CSV.
+func WriteCustomerImportReport(w io.Writer, result *models.CustomerImportResult) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write([]string{"line", "key", "action", "message"}); err != nil {
+		return err
+	}
+	for _, row := range result.Rows {
+		msgs := append(append([]string{}, row.Errors...), row.Warnings...)
+		record := []string{strconv.Itoa(row.Line), row.Key, string(row.Action), strings.Join(msgs, "; ")}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
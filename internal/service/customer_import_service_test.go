@@ -0,0 +1,196 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/testutil"
+)
+
+func newCustomerImportTestService(t *testing.T) (*CustomerImportService, *sql.DB) {
+	t.Helper()
+	db := testutil.MigratedDB(t)
+	for _, stmt := range []string{
+		`INSERT INTO users (id, login, pw, first_name, last_name, valid_id, create_time, create_by, change_time, change_by)
+			VALUES (1, 'root@localhost', 'x', 'Admin', 'OTRS', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+		`INSERT INTO customer_company (customer_id, name, valid_id, create_time, create_by, change_time, change_by)
+			VALUES ('acme', 'Acme Inc', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+		`INSERT INTO customer_user (login, email, customer_id, pw, first_name, last_name, city, valid_id,
+			create_time, create_by, change_time, change_by)
+			VALUES ('jdoe', 'jdoe@acme.test', 'acme', 'old-hash', 'John', 'Doe', 'Berlin', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+	} {
+		_, err := db.Exec(stmt)
+		require.NoError(t, err, stmt)
+	}
+	return NewCustomerImportService(db), db
+}
+
+func TestDetectCustomerImportMapping(t *testing.T) {
+	mapping := DetectCustomerImportMapping(models.CustomerImportUsers,
+		[]string{"UserLogin", "E-Mail", "Customer ID", "First Name", "Notes", "login"})
+	assert.Equal(t, []string{"login", "email", "customer_id", "first_name", "", ""}, mapping)
+
+	mapping = DetectCustomerImportMapping(models.CustomerImportCompanies, []string{"CustomerCompanyName", "customer_id"})
+	assert.Equal(t, []string{"name", "customer_id"}, mapping)
+}
+
+func TestCustomerImportService_PreviewUsers(t *testing.T) {
+	svc, _ := newCustomerImportTestService(t)
+	ctx := context.Background()
+
+	file := "login;email;customer_id;valid\n" +
+		"asmith;asmith@acme.test;acme;valid\n" +
+		"JDOE;john@acme.test;acme;\n" +
+		"bad;not-an-email;;maybe\n" +
+		";;;\n" +
+		"asmith;a2@acme.test;nowhere;1\n" +
+		"bjones;bjones@globex.test;globex;2\n"
+
+	preview, err := svc.Preview(ctx, models.CustomerImportUsers, "users.csv", []byte(file), CustomerImportOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"login", "email", "customer_id", "valid_id"}, preview.Mapping)
+	require.Len(t, preview.Rows, 5, "blank rows are left out")
+	assert.Equal(t, 2, preview.Creates)
+	assert.Equal(t, 1, preview.Skips)
+	assert.Equal(t, 2, preview.Invalid)
+
+	assert.Equal(t, models.CustomerImportCreate, preview.Rows[0].Action)
+	assert.Equal(t, "1", preview.Rows[0].Values["valid_id"])
+
+	assert.Equal(t, models.CustomerImportSkip, preview.Rows[1].Action)
+	assert.Equal(t, "jdoe", preview.Rows[1].Key, "existing logins match regardless of case")
+
+	bad := preview.Rows[2]
+	assert.Equal(t, models.CustomerImportError, bad.Action)
+	assert.Equal(t, 4, bad.Line)
+	assert.Len(t, bad.Errors, 3)
+
+	assert.Equal(t, models.CustomerImportError, preview.Rows[3].Action)
+	assert.Contains(t, preview.Rows[3].Errors[0], "already appears on line 2")
+
+	assert.Equal(t, models.CustomerImportCreate, preview.Rows[4].Action)
+	assert.Contains(t, preview.Rows[4].Warnings, "Customer company globex does not exist")
+
+	preview, err = svc.Preview(ctx, models.CustomerImportUsers, "users.csv", []byte(file), CustomerImportOptions{UpdateExisting: true})
+	require.NoError(t, err)
+	assert.Equal(t, models.CustomerImportUpdate, preview.Rows[1].Action)
+}
+
+func TestCustomerImportService_PreviewErrors(t *testing.T) {
+	svc, _ := newCustomerImportTestService(t)
+	ctx := context.Background()
+
+	_, err := svc.Preview(ctx, "agents", "a.csv", []byte("login\nx"), CustomerImportOptions{})
+	assert.ErrorIs(t, err, ErrCustomerImportKind)
+
+	_, err = svc.Preview(ctx, models.CustomerImportUsers, "a.csv", []byte("login;email"), CustomerImportOptions{})
+	assert.ErrorIs(t, err, ErrCustomerImportFile)
+
+	_, err = svc.Preview(ctx, models.CustomerImportUsers, "a.xlsx", []byte("login;email\nx;y"), CustomerImportOptions{})
+	assert.ErrorIs(t, err, ErrCustomerImportFile)
+
+	for _, mapping := range [][]string{{"login", "login"}, {"login", "shoe_size"}, {"login", "email", "city"}} {
+		_, err = svc.Preview(ctx, models.CustomerImportUsers, "a.csv", []byte("a;b\nx;y"), CustomerImportOptions{Mapping: mapping})
+		assert.ErrorIs(t, err, ErrCustomerImportMapping, mapping)
+	}
+}
+
+func TestCustomerImportService_ImportUsers(t *testing.T) {
+	svc, db := newCustomerImportTestService(t)
+	ctx := context.Background()
+
+	// The mapping ignores the second column and renames the rest.
+	file := "user,ignored,mail,company,pass,surname\n" +
+		"asmith,x,asmith@acme.test,acme,s3cret-Pass,Smith\n" +
+		"jdoe,x,john.doe@acme.test,acme,,Doe-Miller\n" +
+		"broken,x,,acme,,\n"
+	opts := CustomerImportOptions{
+		Mapping:        []string{"login", "", "email", "customer_id", "password", "last_name"},
+		UpdateExisting: true,
+	}
+	result, err := svc.Import(ctx, models.CustomerImportUsers, "users.csv", []byte(file), opts, 7)
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Created)
+	assert.Equal(t, 1, result.Updated)
+	assert.Equal(t, 1, result.Failed)
+
+	var email, lastName, pw, firstName string
+	var validID, createBy int
+	require.NoError(t, db.QueryRow(`SELECT email, last_name, pw, first_name, valid_id, create_by FROM customer_user WHERE login = 'asmith'`).
+		Scan(&email, &lastName, &pw, &firstName, &validID, &createBy))
+	assert.Equal(t, "asmith@acme.test", email)
+	assert.Equal(t, "Smith", lastName)
+	assert.NotEqual(t, "s3cret-Pass", pw, "passwords are stored hashed")
+	assert.True(t, svc.hasher.VerifyPassword("s3cret-Pass", pw))
+	assert.Equal(t, "", firstName)
+	assert.Equal(t, 1, validID)
+	assert.Equal(t, 7, createBy)
+
+	var city string
+	require.NoError(t, db.QueryRow(`SELECT email, last_name, pw, first_name, city FROM customer_user WHERE login = 'jdoe'`).
+		Scan(&email, &lastName, &pw, &firstName, &city))
+	assert.Equal(t, "john.doe@acme.test", email)
+	assert.Equal(t, "Doe-Miller", lastName)
+	assert.Equal(t, "old-hash", pw, "an empty password keeps the current one")
+	assert.Equal(t, "John", firstName, "unmapped fields are kept")
+	assert.Equal(t, "Berlin", city)
+
+	var report bytes.Buffer
+	require.NoError(t, WriteCustomerImportReport(&report, result))
+	assert.Equal(t, "line,key,action,message\n"+
+		"2,asmith,create,\n"+
+		"3,jdoe,update,\n"+
+		"4,broken,error,Email is required\n", report.String())
+}
+
+func TestCustomerImportService_ImportCompanies(t *testing.T) {
+	svc, db := newCustomerImportTestService(t)
+	ctx := context.Background()
+
+	file := "customer_id;name;city;valid_id\n" +
+		"globex;Globex Corp;Springfield;\n" +
+		"initech;Acme Inc;Austin;\n" +
+		"ACME;Acme Incorporated;;invalid\n" +
+		"hooli;Globex Corp;;\n"
+	result, err := svc.Import(ctx, models.CustomerImportCompanies, "companies.csv", []byte(file),
+		CustomerImportOptions{UpdateExisting: true}, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Created)
+	assert.Equal(t, 1, result.Updated)
+	assert.Equal(t, 2, result.Failed)
+	assert.Contains(t, result.Rows[1].Errors[0], "already used by customer company acme")
+	assert.Contains(t, result.Rows[3].Errors[0], "already appears on line 2")
+
+	var name string
+	var validID int
+	require.NoError(t, db.QueryRow(`SELECT name, valid_id FROM customer_company WHERE customer_id = 'acme'`).Scan(&name, &validID))
+	assert.Equal(t, "Acme Incorporated", name)
+	assert.Equal(t, 2, validID)
+	require.NoError(t, db.QueryRow(`SELECT name, valid_id FROM customer_company WHERE customer_id = 'globex'`).Scan(&name, &validID))
+	assert.Equal(t, "Globex Corp", name)
+	assert.Equal(t, 1, validID)
+}
+
+func TestCustomerImportService_ImportIsAtomic(t *testing.T) {
+	svc, db := newCustomerImportTestService(t)
+	ctx := context.Background()
+
+	// A trigger stands in for a constraint only the database knows about.
+	_, err := db.Exec(`CREATE TRIGGER refuse_hooli BEFORE INSERT ON customer_company
+		WHEN NEW.customer_id = 'hooli' BEGIN SELECT RAISE(ABORT, 'refused'); END`)
+	require.NoError(t, err)
+
+	file := "customer_id;name\nglobex;Globex\nhooli;Hooli\n"
+	_, err = svc.Import(ctx, models.CustomerImportCompanies, "companies.csv", []byte(file), CustomerImportOptions{}, 1)
+	require.Error(t, err)
+
+	var count int
+	require.NoError(t, db.QueryRow(`SELECT COUNT(*) FROM customer_company`).Scan(&count))
+	assert.Equal(t, 1, count, "nothing is written when one record fails")
+}
@@ -0,0 +1,426 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/mail"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/goatkit/goatflow/internal/auth"
+	"github.com/goatkit/goatflow/internal/config"
+	"github.com/goatkit/goatflow/internal/mailqueue"
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/repository"
+	"github.com/goatkit/goatflow/internal/sysconfig"
+)
+
+// Errors returned by CustomerRegistrationService.
+var (
+	ErrRegistrationDisabled    = errors.New("customer registration is disabled")
+	ErrRegistrationInvalid     = errors.New("invalid registration")
+	ErrRegistrationDomain      = errors.New("registration is not open for this email domain")
+	ErrRegistrationCaptcha     = errors.New("captcha verification failed")
+	ErrRegistrationNotFound    = errors.New("customer registration not found")
+	ErrRegistrationLinkInvalid = errors.New("verification link is invalid")
+	ErrRegistrationLinkExpired = errors.New("verification link has expired")
+	ErrRegistrationExists      = errors.New("an account with this email address already exists")
+	ErrRegistrationDecided     = repository.ErrCustomerRegistrationDecided
+	ErrRegistrationSettings    = errors.New("invalid registration settings")
+)
+
+// RegistrationPasswordError is returned when the password of a
+// registration does not meet the customer password policy.
+type RegistrationPasswordError struct {
+	Code string // sysconfig.PasswordValidationError code
+}
+
+func (e *RegistrationPasswordError) Error() string {
+	return "password does not meet the password policy: " + e.Code
+}
+
+const (
+	registrationMaxNameLen   = 100
+	registrationMaxTokenDays = 30
+)
+
+var registrationDomain = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?(\.[a-z0-9]([a-z0-9-]*[a-z0-9])?)+$`)
+
+// CustomerRegistrationInput is a signup from the customer portal.
+type CustomerRegistrationInput struct {
+	Email           string `json:"email"`
+	FirstName       string `json:"first_name"`
+	LastName        string `json:"last_name"`
+	Password        string `json:"password"`
+	CaptchaResponse string `json:"captcha_response"`
+	RemoteIP        string `json:"-"`
+}
+
+// registrationMailQueue queues registration emails, normally a
+// *mailqueue.MailQueueRepository.
+type registrationMailQueue interface {
+	Insert(ctx context.Context, item *mailqueue.MailQueueItem) error
+}
+
+// CustomerRegistrationService runs customer portal self-registration: it
+// verifies email addresses with tokenized links, assigns registrations to
+// a company by email domain and, in approval mode, holds them for an admin
+// before the customer_user account is created.
+type CustomerRegistrationService struct {
+	db      *sql.DB
+	repo    *repository.CustomerRegistrationRepository
+	policy  *PasswordPolicyService
+	mail    registrationMailQueue
+	captcha CaptchaVerifier
+	from    string
+	baseURL string
+	now     func() time.Time
+}
+
+// NewCustomerRegistrationService creates a registration service. Emails are
+// queued from email.from, with links to app.base_url.
+func NewCustomerRegistrationService(db *sql.DB) *CustomerRegistrationService {
+	s := &CustomerRegistrationService{
+		db:     db,
+		repo:   repository.NewCustomerRegistrationRepository(db),
+		policy: NewPasswordPolicyService(db),
+		mail:   mailqueue.NewMailQueueRepository(db),
+		now:    time.Now,
+	}
+	if cfg := config.Get(); cfg != nil {
+		s.from = cfg.Email.From
+		s.baseURL = cfg.App.BaseURL
+	}
+	return s
+}
+
+// WithCaptchaVerifier replaces the verifier of the configured CAPTCHA
+// provider, for tests and custom integrations.
+func (s *CustomerRegistrationService) WithCaptchaVerifier(v CaptchaVerifier) *CustomerRegistrationService {
+	s.captcha = v
+	return s
+}
+
+// Settings returns the registration settings.
+func (s *CustomerRegistrationService) Settings() (sysconfig.CustomerRegistrationConfig, error) {
+	return sysconfig.LoadCustomerRegistrationConfig(s.db)
+}
+
+// SaveSettings validates and stores the registration settings. An empty
+// CAPTCHA secret keeps the stored one.
+func (s *CustomerRegistrationService) SaveSettings(cfg sysconfig.CustomerRegistrationConfig, userID int) (sysconfig.CustomerRegistrationConfig, error) {
+	if cfg.TokenValidHours == 0 {
+		cfg.TokenValidHours = sysconfig.DefaultCustomerRegistrationConfig().TokenValidHours
+	}
+	if cfg.TokenValidHours < 1 || cfg.TokenValidHours > registrationMaxTokenDays*24 {
+		return cfg, fmt.Errorf("%w: token_valid_hours must be between 1 and %d", ErrRegistrationSettings, registrationMaxTokenDays*24)
+	}
+	cfg.DefaultCustomerID = strings.TrimSpace(cfg.DefaultCustomerID)
+	seen := make(map[string]bool, len(cfg.DomainMappings))
+	for i, m := range cfg.DomainMappings {
+		m.Domain = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(m.Domain), "@"))
+		m.CustomerID = strings.TrimSpace(m.CustomerID)
+		switch {
+		case !registrationDomain.MatchString(m.Domain):
+			return cfg, fmt.Errorf("%w: invalid domain %q", ErrRegistrationSettings, m.Domain)
+		case seen[m.Domain]:
+			return cfg, fmt.Errorf("%w: domain %q is mapped twice", ErrRegistrationSettings, m.Domain)
+		case m.CustomerID == "":
+			return cfg, fmt.Errorf("%w: domain %q has no customer_id", ErrRegistrationSettings, m.Domain)
+		}
+		seen[m.Domain] = true
+		cfg.DomainMappings[i] = m
+	}
+	if cfg.MappedDomainsOnly && len(cfg.DomainMappings) == 0 {
+		return cfg, fmt.Errorf("%w: mapped_domains_only needs at least one domain mapping", ErrRegistrationSettings)
+	}
+
+	if cfg.Captcha.Provider != "" {
+		if _, err := NewCaptchaVerifier(cfg.Captcha); err != nil {
+			return cfg, fmt.Errorf("%w: captcha provider must be one of %s", ErrRegistrationSettings, strings.Join(CaptchaProviders(), ", "))
+		}
+		if cfg.Captcha.Secret == "" {
+			current, err := s.Settings()
+			if err != nil {
+				return cfg, err
+			}
+			cfg.Captcha.Secret = current.Captcha.Secret
+		}
+		if cfg.Captcha.SiteKey == "" || cfg.Captcha.Secret == "" {
+			return cfg, fmt.Errorf("%w: captcha needs a site_key and a secret", ErrRegistrationSettings)
+		}
+	} else {
+		cfg.Captcha = sysconfig.CaptchaConfig{}
+	}
+
+	if err := sysconfig.SaveCustomerRegistrationConfig(s.db, cfg, userID); err != nil {
+		return cfg, err
+	}
+	return cfg, nil
+}
+
+// Register starts a registration and emails its verification link. To not
+// reveal which addresses have accounts, it succeeds without a new link
+// when the address already has an account or awaits approval; an existing
+// account gets a notice instead.
+func (s *CustomerRegistrationService) Register(ctx context.Context, in CustomerRegistrationInput) error {
+	cfg, err := s.Settings()
+	if err != nil {
+		return err
+	}
+	if !cfg.Enabled {
+		return ErrRegistrationDisabled
+	}
+
+	email, err := normalizeRegistrationEmail(in.Email)
+	if err != nil {
+		return err
+	}
+	first, last := strings.TrimSpace(in.FirstName), strings.TrimSpace(in.LastName)
+	if first == "" || last == "" {
+		return fmt.Errorf("%w: first and last name are required", ErrRegistrationInvalid)
+	}
+	if utf8.RuneCountInString(first) > registrationMaxNameLen || utf8.RuneCountInString(last) > registrationMaxNameLen {
+		return fmt.Errorf("%w: names are limited to %d characters", ErrRegistrationInvalid, registrationMaxNameLen)
+	}
+
+	if in.Password == "" {
+		return fmt.Errorf("%w: a password is required", ErrRegistrationInvalid)
+	}
+
+	if err := s.verifyCaptcha(ctx, cfg.Captcha, in.CaptchaResponse, in.RemoteIP); err != nil {
+		return err
+	}
+
+	customerID, mapped := cfg.CustomerIDFor(email)
+	if cfg.MappedDomainsOnly && !mapped {
+		return ErrRegistrationDomain
+	}
+
+	verr, err := s.policy.CheckPassword(ctx, PasswordAccountCustomer, email, in.Password)
+	if err != nil {
+		return err
+	}
+	if verr != nil {
+		return &RegistrationPasswordError{Code: verr.Code}
+	}
+
+	now := s.now()
+	if err := s.repo.DeleteUnverified(ctx, email, now); err != nil {
+		return err
+	}
+	exists, err := s.repo.CustomerUserExists(ctx, email)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return s.queueMail(ctx, email, "Your account",
+			"Someone, hopefully you, tried to create an account with this email address, but you already have one.\n\n"+
+				"Sign in at "+s.link("/customer/login")+" or reset your password there if you forgot it.\n", now)
+	}
+	pending, err := s.repo.ExistsForEmail(ctx, email, models.CustomerRegistrationPending)
+	if err != nil || pending {
+		return err
+	}
+
+	hash, err := auth.NewPasswordHasher().HashPassword(in.Password)
+	if err != nil {
+		return fmt.Errorf("hash password: %w", err)
+	}
+	token, tokenHash, err := newSurveyToken()
+	if err != nil {
+		return err
+	}
+	reg := &models.CustomerRegistration{
+		Email:      email,
+		FirstName:  first,
+		LastName:   last,
+		CustomerID: customerID,
+		Password:   hash,
+		Status:     models.CustomerRegistrationUnverified,
+		RemoteIP:   in.RemoteIP,
+		ExpiresAt:  now.Add(time.Duration(cfg.TokenValidHours) * time.Hour),
+		CreateTime: now,
+		ChangeTime: now,
+	}
+	if err := s.repo.Create(ctx, reg, tokenHash); err != nil {
+		return err
+	}
+
+	body := fmt.Sprintf("Hello %s,\n\nplease confirm your email address to complete your registration:\n\n%s\n\n"+
+		"The link is valid for %d hours. If you did not register, ignore this email.\n",
+		first, s.link("/customer/register/verify/"+token), cfg.TokenValidHours)
+	if err := s.queueMail(ctx, email, "Confirm your email address", body, now); err != nil {
+		if delErr := s.repo.Delete(ctx, reg.ID); delErr != nil {
+			log.Printf("customer registration: removing unsent registration %d failed: %v", reg.ID, delErr)
+		}
+		return err
+	}
+	return nil
+}
+
+// verifyCaptcha checks the CAPTCHA response when a provider is configured.
+// A provider that cannot be reached fails the registration.
+func (s *CustomerRegistrationService) verifyCaptcha(ctx context.Context, cfg sysconfig.CaptchaConfig, response, remoteIP string) error {
+	verifier := s.captcha
+	if verifier == nil {
+		var err error
+		if verifier, err = NewCaptchaVerifier(cfg); err != nil {
+			return err
+		}
+	}
+	if verifier == nil {
+		return nil
+	}
+	ok, err := verifier.Verify(ctx, response, remoteIP)
+	if err != nil {
+		log.Printf("customer registration: %v", err)
+		return ErrRegistrationCaptcha
+	}
+	if !ok {
+		return ErrRegistrationCaptcha
+	}
+	return nil
+}
+
+// Verify confirms the email address behind a verification link. The
+// account is created right away, or in approval mode the registration
+// waits for an admin; the returned registration's status tells which.
+func (s *CustomerRegistrationService) Verify(ctx context.Context, token string) (*models.CustomerRegistration, error) {
+	if token == "" {
+		return nil, ErrRegistrationLinkInvalid
+	}
+	reg, err := s.repo.GetByTokenHash(ctx, surveyTokenHash(token))
+	if err != nil {
+		return nil, err
+	}
+	if reg == nil || reg.Status != models.CustomerRegistrationUnverified {
+		return nil, ErrRegistrationLinkInvalid
+	}
+	now := s.now()
+	if !now.Before(reg.ExpiresAt) {
+		return nil, ErrRegistrationLinkExpired
+	}
+
+	cfg, err := s.Settings()
+	if err != nil {
+		return nil, err
+	}
+	if cfg.RequireApproval {
+		if err := s.repo.MarkVerified(ctx, reg.ID, now); err != nil {
+			return nil, err
+		}
+		reg.Status = models.CustomerRegistrationPending
+		reg.VerifiedAt = &now
+		return reg, nil
+	}
+	if err := s.activate(ctx, reg, models.CustomerRegistrationUnverified, nil, now); err != nil {
+		return nil, err
+	}
+	return reg, nil
+}
+
+// List returns registrations, newest first, optionally only those in one
+// state.
+func (s *CustomerRegistrationService) List(ctx context.Context, status string, limit, offset int) ([]*models.CustomerRegistration, error) {
+	switch status {
+	case "", models.CustomerRegistrationUnverified, models.CustomerRegistrationPending,
+		models.CustomerRegistrationApproved, models.CustomerRegistrationRejected:
+	default:
+		return nil, fmt.Errorf("%w: unknown status %q", ErrRegistrationInvalid, status)
+	}
+	return s.repo.List(ctx, status, limit, offset)
+}
+
+// Approve creates the account of a verified registration that awaits
+// approval and tells the customer they can sign in.
+func (s *CustomerRegistrationService) Approve(ctx context.Context, id int64, userID int) (*models.CustomerRegistration, error) {
+	reg, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if reg == nil {
+		return nil, ErrRegistrationNotFound
+	}
+	if reg.Status != models.CustomerRegistrationPending {
+		return nil, ErrRegistrationDecided
+	}
+	now := s.now()
+	if err := s.activate(ctx, reg, models.CustomerRegistrationPending, &userID, now); err != nil {
+		return nil, err
+	}
+	reg.DecidedAt, reg.DecidedBy = &now, &userID
+
+	body := fmt.Sprintf("Hello %s,\n\nyour account has been approved. You can now sign in at:\n\n%s\n",
+		reg.FirstName, s.link("/customer/login"))
+	if err := s.queueMail(ctx, reg.Email, "Your account has been approved", body, now); err != nil {
+		log.Printf("customer registration: queueing approval email for %s failed: %v", reg.Email, err)
+	}
+	return reg, nil
+}
+
+// Reject declines an unverified or pending registration.
+func (s *CustomerRegistrationService) Reject(ctx context.Context, id int64, userID int) (*models.CustomerRegistration, error) {
+	reg, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if reg == nil {
+		return nil, ErrRegistrationNotFound
+	}
+	now := s.now()
+	if err := s.repo.Reject(ctx, id, userID, now); err != nil {
+		return nil, err
+	}
+	reg.Status, reg.DecidedAt, reg.DecidedBy = models.CustomerRegistrationRejected, &now, &userID
+	return reg, nil
+}
+
+// activate creates the customer_user account of a registration. An account
+// created for the address in the meantime wins.
+func (s *CustomerRegistrationService) activate(ctx context.Context, reg *models.CustomerRegistration, from string, decidedBy *int, now time.Time) error {
+	exists, err := s.repo.CustomerUserExists(ctx, reg.Email)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return ErrRegistrationExists
+	}
+	if err := s.repo.Activate(ctx, reg, from, decidedBy, now); err != nil {
+		return err
+	}
+	if err := s.policy.RecordChange(ctx, PasswordAccountCustomer, reg.Email, reg.Password); err != nil {
+		log.Printf("customer registration: recording password history for %s failed: %v", reg.Email, err)
+	}
+	return nil
+}
+
+func (s *CustomerRegistrationService) queueMail(ctx context.Context, to, subject, body string, now time.Time) error {
+	sender := s.from
+	return s.mail.Insert(ctx, &mailqueue.MailQueueItem{
+		Sender:     &sender,
+		Recipient:  to,
+		RawMessage: mailqueue.BuildEmailMessage(s.from, to, subject, body),
+		CreateTime: now,
+	})
+}
+
+func (s *CustomerRegistrationService) link(path string) string {
+	return strings.TrimRight(s.baseURL, "/") + path
+}
+
+// normalizeRegistrationEmail validates a bare email address and lowercases
+// it; it is also the login of the account.
+func normalizeRegistrationEmail(email string) (string, error) {
+	email = strings.TrimSpace(email)
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email || len(email) > 150 {
+		return "", fmt.Errorf("%w: a valid email address is required", ErrRegistrationInvalid)
+	}
+	return strings.ToLower(email), nil
+}
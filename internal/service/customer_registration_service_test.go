@@ -0,0 +1,226 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/sysconfig"
+	"github.com/goatkit/goatflow/internal/testutil"
+)
+
+// stubCaptcha accepts the response "ok".
+type stubCaptcha struct{}
+
+func (stubCaptcha) Verify(_ context.Context, response, _ string) (bool, error) {
+	return response == "ok", nil
+}
+
+func TestCustomerRegistrationConfigCustomerIDFor(t *testing.T) {
+	cfg := sysconfig.CustomerRegistrationConfig{
+		DomainMappings: []sysconfig.CustomerRegistrationDomain{{Domain: "acme.com", CustomerID: "ACME"}},
+	}
+	for email, want := range map[string]struct {
+		id     string
+		mapped bool
+	}{
+		"ann@acme.com":      {"ACME", true},
+		"bob@eu.ACME.com":   {"ACME", true},
+		"eve@notacme.com":   {"notacme.com", false},
+		"joe@example.org":   {"example.org", false},
+		"not-an-email-addr": {"", false},
+	} {
+		id, mapped := cfg.CustomerIDFor(email)
+		assert.Equal(t, want.id, id, email)
+		assert.Equal(t, want.mapped, mapped, email)
+	}
+
+	cfg.DefaultCustomerID = "WEB"
+	id, mapped := cfg.CustomerIDFor("joe@example.org")
+	assert.Equal(t, "WEB", id)
+	assert.False(t, mapped)
+}
+
+func TestCustomerRegistrationService(t *testing.T) {
+	db := testutil.UseMigratedDB(t)
+	now := time.Now().UTC().Truncate(time.Second)
+	mail := &stubMailQueue{}
+	svc := NewCustomerRegistrationService(db).WithCaptchaVerifier(stubCaptcha{})
+	svc.mail = mail
+	svc.from, svc.baseURL = "support@example.com", "https://help.example.com/"
+	svc.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	in := CustomerRegistrationInput{Email: "Ann@Acme.com", FirstName: "Ann", LastName: "Lee", Password: "correct horse", CaptchaResponse: "ok"}
+	assert.ErrorIs(t, svc.Register(ctx, in), ErrRegistrationDisabled)
+
+	_, err := svc.SaveSettings(sysconfig.CustomerRegistrationConfig{
+		Enabled:        true,
+		DomainMappings: []sysconfig.CustomerRegistrationDomain{{Domain: "acme.com", CustomerID: "x"}, {Domain: "ACME.com", CustomerID: "y"}},
+	}, 1)
+	assert.ErrorIs(t, err, ErrRegistrationSettings, "duplicate domain")
+	_, err = svc.SaveSettings(sysconfig.CustomerRegistrationConfig{Enabled: true, Captcha: sysconfig.CaptchaConfig{Provider: "abacus"}}, 1)
+	assert.ErrorIs(t, err, ErrRegistrationSettings, "unknown captcha provider")
+	saved, err := svc.SaveSettings(sysconfig.CustomerRegistrationConfig{
+		Enabled:           true,
+		MappedDomainsOnly: true,
+		DomainMappings:    []sysconfig.CustomerRegistrationDomain{{Domain: " @Acme.com ", CustomerID: "ACME"}},
+		Captcha:           sysconfig.CaptchaConfig{Provider: "turnstile", SiteKey: "site", Secret: "secret"},
+	}, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "acme.com", saved.DomainMappings[0].Domain)
+	assert.Equal(t, 24, saved.TokenValidHours)
+
+	// An empty secret keeps the stored one
+	saved.Captcha.Secret = ""
+	_, err = svc.SaveSettings(saved, 1)
+	require.NoError(t, err)
+	cfg, err := svc.Settings()
+	require.NoError(t, err)
+	assert.Equal(t, "secret", cfg.Captcha.Secret)
+
+	bad := in
+	bad.CaptchaResponse = "robot"
+	assert.ErrorIs(t, svc.Register(ctx, bad), ErrRegistrationCaptcha)
+	bad = in
+	bad.Email = "Ann <ann@acme.com>"
+	assert.ErrorIs(t, svc.Register(ctx, bad), ErrRegistrationInvalid)
+	bad = in
+	bad.Email = "ann@example.org"
+	assert.ErrorIs(t, svc.Register(ctx, bad), ErrRegistrationDomain)
+
+	require.NoError(t, sysconfig.SaveCustomerPasswordPolicy(db, sysconfig.PasswordPolicy{PasswordMinSize: 10}, 1))
+	bad = in
+	bad.Password = "short"
+	var pwErr *RegistrationPasswordError
+	require.ErrorAs(t, svc.Register(ctx, bad), &pwErr)
+	assert.Equal(t, "min_size", pwErr.Code)
+	assert.Empty(t, mail.items)
+
+	// Registering twice replaces the first link
+	require.NoError(t, svc.Register(ctx, in))
+	require.NoError(t, svc.Register(ctx, in))
+	require.Len(t, mail.items, 2)
+	assert.Equal(t, "ann@acme.com", mail.items[1].Recipient)
+	link := regexp.MustCompile(`https://help\.example\.com/customer/register/verify/([A-Za-z0-9_-]+)`)
+	first := link.FindStringSubmatch(string(mail.items[0].RawMessage))
+	m := link.FindStringSubmatch(string(mail.items[1].RawMessage))
+	require.Len(t, first, 2)
+	require.Len(t, m, 2)
+	_, err = svc.Verify(ctx, first[1])
+	assert.ErrorIs(t, err, ErrRegistrationLinkInvalid, "replaced link")
+
+	svc.now = func() time.Time { return now.Add(25 * time.Hour) }
+	_, err = svc.Verify(ctx, m[1])
+	assert.ErrorIs(t, err, ErrRegistrationLinkExpired)
+	svc.now = func() time.Time { return now }
+
+	reg, err := svc.Verify(ctx, m[1])
+	require.NoError(t, err)
+	assert.Equal(t, models.CustomerRegistrationApproved, reg.Status)
+	var customerID, pw string
+	require.NoError(t, db.QueryRow("SELECT customer_id, pw FROM customer_user WHERE login = 'ann@acme.com'").Scan(&customerID, &pw))
+	assert.Equal(t, "ACME", customerID)
+	assert.NotEqual(t, "correct horse", pw, "password is stored hashed")
+	_, err = svc.Verify(ctx, m[1])
+	assert.ErrorIs(t, err, ErrRegistrationLinkInvalid, "links work once")
+
+	// An existing account gets a notice instead of a link
+	require.NoError(t, svc.Register(ctx, in))
+	require.Len(t, mail.items, 3)
+	assert.Contains(t, string(mail.items[2].RawMessage), "you already have one")
+	assert.NotRegexp(t, link, string(mail.items[2].RawMessage))
+}
+
+func TestCustomerRegistrationServiceApproval(t *testing.T) {
+	db := testutil.UseMigratedDB(t)
+	now := time.Now().UTC().Truncate(time.Second)
+	mail := &stubMailQueue{}
+	svc := NewCustomerRegistrationService(db)
+	svc.mail = mail
+	svc.baseURL = "https://help.example.com"
+	svc.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	_, err := svc.SaveSettings(sysconfig.CustomerRegistrationConfig{Enabled: true, RequireApproval: true, DefaultCustomerID: "WEB"}, 1)
+	require.NoError(t, err)
+
+	verify := func(email string) *models.CustomerRegistration {
+		require.NoError(t, svc.Register(ctx, CustomerRegistrationInput{Email: email, FirstName: "Joe", LastName: "Doe", Password: "secret"}))
+		m := regexp.MustCompile(`/customer/register/verify/([A-Za-z0-9_-]+)`).FindStringSubmatch(string(mail.items[len(mail.items)-1].RawMessage))
+		require.Len(t, m, 2)
+		reg, err := svc.Verify(ctx, m[1])
+		require.NoError(t, err)
+		assert.Equal(t, models.CustomerRegistrationPending, reg.Status)
+		return reg
+	}
+	joe := verify("joe@example.org")
+	eve := verify("eve@example.org")
+
+	// A pending address is not registered again
+	sent := len(mail.items)
+	require.NoError(t, svc.Register(ctx, CustomerRegistrationInput{Email: "joe@example.org", FirstName: "Joe", LastName: "Doe", Password: "secret"}))
+	assert.Len(t, mail.items, sent)
+
+	pending, err := svc.List(ctx, models.CustomerRegistrationPending, 50, 0)
+	require.NoError(t, err)
+	assert.Len(t, pending, 2)
+	_, err = svc.List(ctx, "bogus", 50, 0)
+	assert.ErrorIs(t, err, ErrRegistrationInvalid)
+
+	var n int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM customer_user WHERE login = 'joe@example.org'").Scan(&n))
+	assert.Zero(t, n, "no account before approval")
+
+	approved, err := svc.Approve(ctx, joe.ID, 1)
+	require.NoError(t, err)
+	assert.Equal(t, models.CustomerRegistrationApproved, approved.Status)
+	assert.Contains(t, string(mail.items[len(mail.items)-1].RawMessage), "has been approved")
+	var customerID string
+	require.NoError(t, db.QueryRow("SELECT customer_id FROM customer_user WHERE login = 'joe@example.org'").Scan(&customerID))
+	assert.Equal(t, "WEB", customerID)
+	_, err = svc.Approve(ctx, joe.ID, 1)
+	assert.ErrorIs(t, err, ErrRegistrationDecided)
+
+	rejected, err := svc.Reject(ctx, eve.ID, 1)
+	require.NoError(t, err)
+	assert.Equal(t, models.CustomerRegistrationRejected, rejected.Status)
+	_, err = svc.Approve(ctx, eve.ID, 1)
+	assert.ErrorIs(t, err, ErrRegistrationDecided)
+	_, err = svc.Reject(ctx, 999, 1)
+	assert.ErrorIs(t, err, ErrRegistrationNotFound)
+}
+
+func TestSiteverifyCaptcha(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, r.ParseForm())
+		assert.Equal(t, "secret", r.PostForm.Get("secret"))
+		assert.Equal(t, "203.0.113.9", r.PostForm.Get("remoteip"))
+		if r.PostForm.Get("response") == "good" {
+			_, _ = w.Write([]byte(`{"success":true}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"success":false,"error-codes":["invalid-input-response"]}`))
+	}))
+	defer srv.Close()
+
+	v := &SiteverifyCaptcha{URL: srv.URL, Secret: "secret", Client: srv.Client()}
+	ok, err := v.Verify(context.Background(), "good", "203.0.113.9")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = v.Verify(context.Background(), "bad", "203.0.113.9")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	verifier, err := NewCaptchaVerifier(sysconfig.CaptchaConfig{})
+	require.NoError(t, err)
+	assert.Nil(t, verifier, "no provider, no captcha")
+	assert.Equal(t, []string{"hcaptcha", "recaptcha", "turnstile"}, CaptchaProviders())
+}
@@ -0,0 +1,147 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/repository"
+)
+
+// Errors returned by CustomerStateLabelService.
+var (
+	ErrCustomerStateNotFound      = errors.New("ticket state not found")
+	ErrCustomerStateLabelNotFound = errors.New("no customer label configured for this state")
+	ErrCustomerStateLabelInvalid  = errors.New("invalid customer state label")
+)
+
+// customerStateI18nKey matches dotted translation keys like status.waiting.
+var customerStateI18nKey = regexp.MustCompile(`^[a-z0-9_]+(\.[a-z0-9_]+)+$`)
+
+// customerStateDefaults are the labels of states without a configured one,
+// by state type name. Both pending types read as waiting, all final types as
+// closed.
+var customerStateDefaults = map[string]models.CustomerStateMapping{
+	"new":              {Category: models.CustomerStateNew, Label: "New", I18nKey: "status.new"},
+	"open":             {Category: models.CustomerStateOpen, Label: "In Progress", I18nKey: "status.in_progress"},
+	"pending reminder": {Category: models.CustomerStatePending, Label: "Waiting", I18nKey: "status.waiting"},
+	"pending auto":     {Category: models.CustomerStatePending, Label: "Waiting", I18nKey: "status.waiting"},
+	"closed":           {Category: models.CustomerStateClosed, Label: "Closed", I18nKey: "status.closed"},
+	"removed":          {Category: models.CustomerStateClosed, Label: "Closed", I18nKey: "status.closed"},
+	"merged":           {Category: models.CustomerStateClosed, Label: "Closed", I18nKey: "status.closed"},
+}
+
+// CustomerStateLabelService maps internal ticket states to the labels
+// customers see in the portal and in customer API token responses.
+type CustomerStateLabelService struct {
+	repo *repository.CustomerStateLabelRepository
+	now  func() time.Time
+}
+
+// NewCustomerStateLabelService creates a customer state label service.
+func NewCustomerStateLabelService(db *sql.DB) *CustomerStateLabelService {
+	return &CustomerStateLabelService{repo: repository.NewCustomerStateLabelRepository(db), now: time.Now}
+}
+
+// List returns how customers see every ticket state.
+func (s *CustomerStateLabelService) List(ctx context.Context) ([]*models.CustomerStateMapping, error) {
+	states, err := s.repo.ListStates(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, state := range states {
+		resolveCustomerState(state)
+	}
+	return states, nil
+}
+
+// Mappings returns how customers see every ticket state, by state ID.
+func (s *CustomerStateLabelService) Mappings(ctx context.Context) (map[int]*models.CustomerStateMapping, error) {
+	states, err := s.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[int]*models.CustomerStateMapping, len(states))
+	for _, state := range states {
+		byID[state.StateID] = state
+	}
+	return byID, nil
+}
+
+// GetLabel returns the label configured for a state.
+func (s *CustomerStateLabelService) GetLabel(ctx context.Context, stateID int) (*models.CustomerStateLabel, error) {
+	label, err := s.repo.GetLabel(ctx, stateID)
+	if err != nil {
+		return nil, err
+	}
+	if label == nil {
+		return nil, ErrCustomerStateLabelNotFound
+	}
+	return label, nil
+}
+
+// SetLabel validates and stores the label of a state, replacing an
+// existing one.
+func (s *CustomerStateLabelService) SetLabel(ctx context.Context, label *models.CustomerStateLabel, userID int) error {
+	label.Label = strings.TrimSpace(label.Label)
+	label.I18nKey = strings.TrimSpace(label.I18nKey)
+	if label.Label == "" || len(label.Label) > 200 {
+		return fmt.Errorf("%w: label must be 1 to 200 characters", ErrCustomerStateLabelInvalid)
+	}
+	if label.I18nKey != "" && (len(label.I18nKey) > 200 || !customerStateI18nKey.MatchString(label.I18nKey)) {
+		return fmt.Errorf("%w: i18n_key must be a dotted translation key like status.waiting", ErrCustomerStateLabelInvalid)
+	}
+	ok, err := s.repo.StateExists(ctx, label.StateID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrCustomerStateNotFound
+	}
+	now := s.now()
+	label.ChangeTime, label.ChangeBy = now, userID
+	label.CreateTime, label.CreateBy = now, userID
+	if existing, err := s.repo.GetLabel(ctx, label.StateID); err != nil {
+		return err
+	} else if existing != nil {
+		label.CreateTime, label.CreateBy = existing.CreateTime, existing.CreateBy
+	}
+	return s.repo.SetLabel(ctx, label, userID, now)
+}
+
+// DeleteLabel removes the label of a state; the state's type decides how
+// customers see it again.
+func (s *CustomerStateLabelService) DeleteLabel(ctx context.Context, stateID int) error {
+	deleted, err := s.repo.DeleteLabel(ctx, stateID)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrCustomerStateLabelNotFound
+	}
+	return nil
+}
+
+// DefaultCustomerState returns how customers see a state of the named
+// state type when no label is configured. Unknown types show as open.
+func DefaultCustomerState(stateType string) models.CustomerStateMapping {
+	if def, ok := customerStateDefaults[strings.ToLower(stateType)]; ok {
+		return def
+	}
+	return customerStateDefaults["open"]
+}
+
+// resolveCustomerState fills in the category, and the label of states
+// without a configured one.
+func resolveCustomerState(state *models.CustomerStateMapping) {
+	def := DefaultCustomerState(state.StateType)
+	state.Category = def.Category
+	if !state.Custom {
+		state.Label, state.I18nKey = def.Label, def.I18nKey
+	}
+}
@@ -0,0 +1,72 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/testutil"
+)
+
+func TestCustomerStateLabelService(t *testing.T) {
+	db := testutil.UseMigratedDB(t)
+	_, err := db.Exec(`INSERT INTO users (id, login, pw, first_name, last_name, valid_id, create_time, create_by, change_time, change_by)
+		VALUES (1, 'root@localhost', 'x', 'Admin', 'OTRS', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	s := NewCustomerStateLabelService(db)
+	s.now = func() time.Time { return time.Date(2026, 3, 10, 9, 0, 0, 0, time.UTC) }
+
+	states, err := s.List(ctx)
+	require.NoError(t, err)
+	require.NotEmpty(t, states)
+	for _, state := range states {
+		def := DefaultCustomerState(state.StateType)
+		assert.Equal(t, def.Category, state.Category, state.StateName)
+		assert.Equal(t, def.Label, state.Label, state.StateName)
+		assert.False(t, state.Custom)
+	}
+	stateID := states[0].StateID
+
+	for name, label := range map[string]*models.CustomerStateLabel{
+		"no label":        {StateID: stateID, Label: " "},
+		"bad i18n key":    {StateID: stateID, Label: "Received", I18nKey: "Status Received"},
+		"single-part key": {StateID: stateID, Label: "Received", I18nKey: "received"},
+	} {
+		assert.ErrorIs(t, s.SetLabel(ctx, label, 1), ErrCustomerStateLabelInvalid, name)
+	}
+	assert.ErrorIs(t, s.SetLabel(ctx, &models.CustomerStateLabel{StateID: 9999, Label: "Received"}, 1), ErrCustomerStateNotFound)
+
+	label := &models.CustomerStateLabel{StateID: stateID, Label: " Received ", I18nKey: "status.received"}
+	require.NoError(t, s.SetLabel(ctx, label, 1))
+	assert.Equal(t, "Received", label.Label)
+	loaded, err := s.GetLabel(ctx, stateID)
+	require.NoError(t, err)
+	assert.Equal(t, "status.received", loaded.I18nKey)
+
+	mappings, err := s.Mappings(ctx)
+	require.NoError(t, err)
+	require.Contains(t, mappings, stateID)
+	assert.True(t, mappings[stateID].Custom)
+	assert.Equal(t, "Received", mappings[stateID].Label)
+	assert.Equal(t, states[0].Category, mappings[stateID].Category, "the state type keeps deciding the category")
+
+	require.NoError(t, s.DeleteLabel(ctx, stateID))
+	assert.ErrorIs(t, s.DeleteLabel(ctx, stateID), ErrCustomerStateLabelNotFound)
+	_, err = s.GetLabel(ctx, stateID)
+	assert.ErrorIs(t, err, ErrCustomerStateLabelNotFound)
+}
+
+func TestDefaultCustomerState(t *testing.T) {
+	assert.Equal(t, models.CustomerStateNew, DefaultCustomerState("new").Category)
+	assert.Equal(t, "In Progress", DefaultCustomerState("open").Label)
+	assert.Equal(t, models.CustomerStatePending, DefaultCustomerState("pending reminder").Category)
+	assert.Equal(t, "status.waiting", DefaultCustomerState("Pending Auto").I18nKey)
+	assert.Equal(t, models.CustomerStateClosed, DefaultCustomerState("merged").Category)
+	assert.Equal(t, models.CustomerStateOpen, DefaultCustomerState("escalated").Category, "unknown types show as open")
+}
@@ -0,0 +1,202 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/repository"
+	"github.com/goatkit/goatflow/internal/sysconfig"
+)
+
+// Errors returned by EmailLoopService.
+var (
+	ErrEmailBounceNotFound = errors.New("email bounce not found")
+	ErrEmailLoopInvalid    = errors.New("invalid email loop settings")
+)
+
+// softBounceWindow is how long soft bounces count towards the soft bounce
+// limit; a soft bounce after a quiet window starts counting anew.
+const softBounceWindow = 7 * 24 * time.Hour
+
+// Reasons EmailLoopService.Suppressed returns.
+const (
+	EmailSuppressedBounced = "bounced"    // Auto responses are paused after bounces
+	EmailSuppressedRate    = "rate limit" // The daily maximum was reached
+)
+
+// EmailLoopService protects against mail loops: it limits the automatic
+// emails sent to an address per day and tracks bounces, pausing automatic
+// emails to addresses that bounce.
+type EmailLoopService struct {
+	db        *sql.DB
+	repo      *repository.EmailLoopRepository
+	addresses *repository.AutoResponseRepository
+	now       func() time.Time
+}
+
+// NewEmailLoopService creates an email loop service.
+func NewEmailLoopService(db *sql.DB) *EmailLoopService {
+	return &EmailLoopService{
+		db:        db,
+		repo:      repository.NewEmailLoopRepository(db),
+		addresses: repository.NewAutoResponseRepository(db),
+		now:       time.Now,
+	}
+}
+
+// Settings returns the loop and bounce settings.
+func (s *EmailLoopService) Settings() (sysconfig.EmailLoopConfig, error) {
+	return sysconfig.LoadEmailLoopConfig(s.db)
+}
+
+// SaveSettings validates and stores the loop and bounce settings.
+func (s *EmailLoopService) SaveSettings(cfg sysconfig.EmailLoopConfig, userID int) error {
+	if cfg.MaxEmailsPerAddress < 0 || cfg.MaxEmailsPerAddress > 10000 {
+		return fmt.Errorf("%w: max_emails_per_address must be 0 to 10000", ErrEmailLoopInvalid)
+	}
+	if cfg.SoftBounceLimit < 1 || cfg.SoftBounceLimit > 100 {
+		return fmt.Errorf("%w: soft_bounce_limit must be 1 to 100", ErrEmailLoopInvalid)
+	}
+	return sysconfig.SaveEmailLoopConfig(s.db, cfg, userID)
+}
+
+// IsSystemAddress reports whether an address is one of the system's own.
+func (s *EmailLoopService) IsSystemAddress(ctx context.Context, address string) (bool, error) {
+	return s.addresses.IsSystemAddress(ctx, address)
+}
+
+// day returns the current day in the sent_date format of OTRS.
+func (s *EmailLoopService) day() string {
+	t := s.now()
+	return fmt.Sprintf("%d-%d-%d", t.Year(), t.Month(), t.Day())
+}
+
+// SenderExceeded reports whether an address got the maximum number of
+// automatic emails today. A maximum of 0 disables the limit.
+func (s *EmailLoopService) SenderExceeded(ctx context.Context, address string) (bool, error) {
+	cfg, err := s.Settings()
+	if err != nil || cfg.MaxEmailsPerAddress <= 0 {
+		return false, err
+	}
+	count, err := s.repo.CountSent(ctx, strings.ToLower(address), s.day())
+	if err != nil {
+		return false, err
+	}
+	return count >= cfg.MaxEmailsPerAddress, nil
+}
+
+// RecordSent counts an automatic email sent to an address.
+func (s *EmailLoopService) RecordSent(ctx context.Context, address string) error {
+	return s.repo.AddSent(ctx, strings.ToLower(address), s.day())
+}
+
+// Suppressed returns why automatic emails to an address are suppressed, or
+// "" when they may be sent.
+func (s *EmailLoopService) Suppressed(ctx context.Context, address string) (string, error) {
+	bounce, err := s.Bounce(ctx, address)
+	if err != nil && !errors.Is(err, ErrEmailBounceNotFound) {
+		return "", err
+	}
+	if bounce != nil && bounce.Paused {
+		return EmailSuppressedBounced, nil
+	}
+	exceeded, err := s.SenderExceeded(ctx, address)
+	if err != nil {
+		return "", err
+	}
+	if exceeded {
+		return EmailSuppressedRate, nil
+	}
+	return "", nil
+}
+
+// RecordBounce adds a bounce report for report.Email to its bounce state.
+func (s *EmailLoopService) RecordBounce(ctx context.Context, report models.EmailBounce) (*models.EmailBounce, error) {
+	email := strings.ToLower(strings.TrimSpace(report.Email))
+	if email == "" {
+		return nil, fmt.Errorf("%w: bounce without recipient", ErrEmailLoopInvalid)
+	}
+	if report.BounceType != models.BounceTypeHard && report.BounceType != models.BounceTypeSoft {
+		return nil, fmt.Errorf("%w: unknown bounce type %q", ErrEmailLoopInvalid, report.BounceType)
+	}
+	b, err := s.repo.GetBounce(ctx, email)
+	if err != nil {
+		return nil, err
+	}
+	now := s.now()
+	if b == nil {
+		b = &models.EmailBounce{Email: email, FirstBounceTime: now}
+	}
+	if report.BounceType == models.BounceTypeHard {
+		b.HardCount++
+	} else {
+		if now.Sub(b.LastBounceTime) > softBounceWindow {
+			b.SoftCount = 0
+		}
+		b.SoftCount++
+	}
+	b.BounceType, b.LastBounceTime = report.BounceType, now
+	b.Status = truncateString(report.Status, 20)
+	b.Diagnostic = truncateString(report.Diagnostic, 500)
+	if err := s.repo.SaveBounce(ctx, b); err != nil {
+		return nil, err
+	}
+	return b, s.setPaused(b)
+}
+
+// Bounce returns the bounce state of an address.
+func (s *EmailLoopService) Bounce(ctx context.Context, email string) (*models.EmailBounce, error) {
+	b, err := s.repo.GetBounce(ctx, email)
+	if err != nil {
+		return nil, err
+	}
+	if b == nil {
+		return nil, ErrEmailBounceNotFound
+	}
+	return b, s.setPaused(b)
+}
+
+// Bounces returns the bounce state of the given addresses that bounced, or
+// of all bounced addresses when none are given.
+func (s *EmailLoopService) Bounces(ctx context.Context, emails ...string) ([]*models.EmailBounce, error) {
+	bounces, err := s.repo.ListBounces(ctx, emails...)
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range bounces {
+		if err := s.setPaused(b); err != nil {
+			return nil, err
+		}
+	}
+	return bounces, nil
+}
+
+// ClearBounce resets the bounce state of an address, resuming automatic
+// emails to it.
+func (s *EmailLoopService) ClearBounce(ctx context.Context, email string) error {
+	deleted, err := s.repo.DeleteBounce(ctx, email)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrEmailBounceNotFound
+	}
+	return nil
+}
+
+// setPaused sets b.Paused: after a hard bounce, or when the soft bounce
+// limit was reached within the soft bounce window.
+func (s *EmailLoopService) setPaused(b *models.EmailBounce) error {
+	cfg, err := s.Settings()
+	if err != nil {
+		return err
+	}
+	b.Paused = b.BounceType == models.BounceTypeHard ||
+		(b.SoftCount >= cfg.SoftBounceLimit && s.now().Sub(b.LastBounceTime) <= softBounceWindow)
+	return nil
+}
@@ -0,0 +1,104 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/sysconfig"
+	"github.com/goatkit/goatflow/internal/testutil"
+)
+
+func TestEmailLoopService(t *testing.T) {
+	db := testutil.UseMigratedDB(t)
+	ctx := context.Background()
+	s := NewEmailLoopService(db)
+	clock := time.Date(2026, 3, 10, 9, 0, 0, 0, time.UTC)
+	s.now = func() time.Time { return clock }
+
+	assert.ErrorIs(t, s.SaveSettings(sysconfig.EmailLoopConfig{MaxEmailsPerAddress: -1, SoftBounceLimit: 3}, 1), ErrEmailLoopInvalid)
+	assert.ErrorIs(t, s.SaveSettings(sysconfig.EmailLoopConfig{MaxEmailsPerAddress: 2, SoftBounceLimit: 0}, 1), ErrEmailLoopInvalid)
+	require.NoError(t, s.SaveSettings(sysconfig.EmailLoopConfig{MaxEmailsPerAddress: 2, SoftBounceLimit: 2}, 1))
+	cfg, err := s.Settings()
+	require.NoError(t, err)
+	assert.Equal(t, sysconfig.EmailLoopConfig{MaxEmailsPerAddress: 2, SoftBounceLimit: 2}, cfg)
+
+	t.Run("daily maximum", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			reason, err := s.Suppressed(ctx, "bob@example.com")
+			require.NoError(t, err)
+			require.Empty(t, reason)
+			require.NoError(t, s.RecordSent(ctx, "Bob@example.com"))
+		}
+		reason, err := s.Suppressed(ctx, "bob@example.com")
+		require.NoError(t, err)
+		assert.Equal(t, EmailSuppressedRate, reason)
+
+		clock = clock.Add(24 * time.Hour)
+		exceeded, err := s.SenderExceeded(ctx, "bob@example.com")
+		require.NoError(t, err)
+		assert.False(t, exceeded, "the count starts anew every day")
+	})
+
+	t.Run("soft bounces", func(t *testing.T) {
+		b, err := s.RecordBounce(ctx, models.EmailBounce{Email: "Carol@example.com", BounceType: models.BounceTypeSoft,
+			Status: "4.2.2", Diagnostic: "mailbox full"})
+		require.NoError(t, err)
+		assert.Equal(t, "carol@example.com", b.Email)
+		assert.False(t, b.Paused)
+
+		clock = clock.Add(8 * 24 * time.Hour)
+		b, err = s.RecordBounce(ctx, models.EmailBounce{Email: "carol@example.com", BounceType: models.BounceTypeSoft})
+		require.NoError(t, err)
+		assert.Equal(t, 1, b.SoftCount, "old soft bounces expire")
+		assert.False(t, b.Paused)
+
+		b, err = s.RecordBounce(ctx, models.EmailBounce{Email: "carol@example.com", BounceType: models.BounceTypeSoft})
+		require.NoError(t, err)
+		assert.Equal(t, 2, b.SoftCount)
+		assert.True(t, b.Paused)
+		reason, err := s.Suppressed(ctx, "carol@example.com")
+		require.NoError(t, err)
+		assert.Equal(t, EmailSuppressedBounced, reason)
+
+		clock = clock.Add(8 * 24 * time.Hour)
+		b, err = s.Bounce(ctx, "carol@example.com")
+		require.NoError(t, err)
+		assert.False(t, b.Paused, "soft bounce pauses end")
+	})
+
+	t.Run("hard bounces", func(t *testing.T) {
+		_, err := s.RecordBounce(ctx, models.EmailBounce{Email: "dave@example.com", BounceType: "weird"})
+		assert.ErrorIs(t, err, ErrEmailLoopInvalid)
+		_, err = s.RecordBounce(ctx, models.EmailBounce{BounceType: models.BounceTypeHard})
+		assert.ErrorIs(t, err, ErrEmailLoopInvalid)
+
+		b, err := s.RecordBounce(ctx, models.EmailBounce{Email: "dave@example.com", BounceType: models.BounceTypeHard,
+			Status: "5.1.1", Diagnostic: "user unknown"})
+		require.NoError(t, err)
+		assert.True(t, b.Paused)
+		assert.Equal(t, 1, b.HardCount)
+
+		bounces, err := s.Bounces(ctx, "DAVE@example.com", "nobody@example.com")
+		require.NoError(t, err)
+		require.Len(t, bounces, 1)
+		assert.Equal(t, "5.1.1", bounces[0].Status)
+		assert.Equal(t, "user unknown", bounces[0].Diagnostic)
+		all, err := s.Bounces(ctx)
+		require.NoError(t, err)
+		assert.Len(t, all, 2)
+
+		require.NoError(t, s.ClearBounce(ctx, "dave@example.com"))
+		assert.ErrorIs(t, s.ClearBounce(ctx, "dave@example.com"), ErrEmailBounceNotFound)
+		_, err = s.Bounce(ctx, "dave@example.com")
+		assert.ErrorIs(t, err, ErrEmailBounceNotFound)
+	})
+
+	own, err := s.IsSystemAddress(ctx, "Junk@goatflow.local")
+	require.NoError(t, err)
+	assert.True(t, own)
+}
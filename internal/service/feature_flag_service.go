@@ -0,0 +1,259 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/goatkit/goatflow/internal/repository"
+	"github.com/goatkit/goatflow/internal/sysconfig"
+)
+
+// Errors returned by FeatureFlagService.
+var (
+	ErrFeatureFlagInvalid  = errors.New("invalid feature flag")
+	ErrFeatureFlagNotFound = errors.New("feature flag not found")
+	ErrFeatureFlagExists   = errors.New("feature flag already exists")
+)
+
+// featureFlagCacheTTL is how long evaluations reuse the loaded flags.
+const featureFlagCacheTTL = 30 * time.Second
+
+var featureFlagKey = regexp.MustCompile(`^[a-z0-9][a-z0-9_.-]*$`)
+
+var featureFlagCache = struct {
+	sync.Mutex
+	flags   []sysconfig.FeatureFlag
+	expires time.Time
+}{}
+
+// invalidateFeatureFlagCache makes the next evaluation reload the flags.
+func invalidateFeatureFlagCache() {
+	featureFlagCache.Lock()
+	featureFlagCache.flags = nil
+	featureFlagCache.expires = time.Time{}
+	featureFlagCache.Unlock()
+}
+
+// FeatureFlagService manages the feature flags stored in sysconfig and
+// evaluates them for users, so features can be dark-launched and rolled out
+// gradually.
+type FeatureFlagService struct {
+	db         *sql.DB
+	userGroups func(userID uint) ([]string, error)
+	now        func() time.Time
+	mu         sync.Mutex // serialises read-modify-write of the flag list
+}
+
+// NewFeatureFlagService creates a feature flag service.
+func NewFeatureFlagService(db *sql.DB) *FeatureFlagService {
+	return &FeatureFlagService{
+		db:         db,
+		userGroups: repository.NewUserRepository(db).GetUserGroups,
+		now:        time.Now,
+	}
+}
+
+// List returns all flags sorted by key.
+func (s *FeatureFlagService) List(ctx context.Context) ([]sysconfig.FeatureFlag, error) {
+	flags, err := sysconfig.LoadFeatureFlags(s.db)
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(flags, func(a, b sysconfig.FeatureFlag) int { return strings.Compare(a.Key, b.Key) })
+	return flags, nil
+}
+
+// Get returns a flag by key.
+func (s *FeatureFlagService) Get(ctx context.Context, key string) (*sysconfig.FeatureFlag, error) {
+	flags, err := sysconfig.LoadFeatureFlags(s.db)
+	if err != nil {
+		return nil, err
+	}
+	if i := featureFlagIndex(flags, key); i >= 0 {
+		return &flags[i], nil
+	}
+	return nil, ErrFeatureFlagNotFound
+}
+
+// Create validates and adds a flag.
+func (s *FeatureFlagService) Create(ctx context.Context, f sysconfig.FeatureFlag, userID int) (*sysconfig.FeatureFlag, error) {
+	if err := normalizeFeatureFlag(&f); err != nil {
+		return nil, err
+	}
+	err := s.update(userID, func(flags []sysconfig.FeatureFlag) ([]sysconfig.FeatureFlag, error) {
+		if featureFlagIndex(flags, f.Key) >= 0 {
+			return nil, ErrFeatureFlagExists
+		}
+		return append(flags, f), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+// Update validates and replaces the flag with the given key; the key itself
+// cannot be changed.
+func (s *FeatureFlagService) Update(ctx context.Context, key string, f sysconfig.FeatureFlag, userID int) (*sysconfig.FeatureFlag, error) {
+	f.Key = key
+	if err := normalizeFeatureFlag(&f); err != nil {
+		return nil, err
+	}
+	err := s.update(userID, func(flags []sysconfig.FeatureFlag) ([]sysconfig.FeatureFlag, error) {
+		i := featureFlagIndex(flags, f.Key)
+		if i < 0 {
+			return nil, ErrFeatureFlagNotFound
+		}
+		flags[i] = f
+		return flags, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+// SetEnabled switches a flag on or off, keeping its targeting.
+func (s *FeatureFlagService) SetEnabled(ctx context.Context, key string, enabled bool, userID int) (*sysconfig.FeatureFlag, error) {
+	var updated sysconfig.FeatureFlag
+	err := s.update(userID, func(flags []sysconfig.FeatureFlag) ([]sysconfig.FeatureFlag, error) {
+		i := featureFlagIndex(flags, key)
+		if i < 0 {
+			return nil, ErrFeatureFlagNotFound
+		}
+		flags[i].Enabled = enabled
+		updated = flags[i]
+		return flags, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &updated, nil
+}
+
+// Delete removes a flag; it then evaluates as off everywhere.
+func (s *FeatureFlagService) Delete(ctx context.Context, key string, userID int) error {
+	return s.update(userID, func(flags []sysconfig.FeatureFlag) ([]sysconfig.FeatureFlag, error) {
+		i := featureFlagIndex(flags, key)
+		if i < 0 {
+			return nil, ErrFeatureFlagNotFound
+		}
+		return slices.Delete(flags, i, i+1), nil
+	})
+}
+
+// update loads the flags, applies fn and stores the result.
+func (s *FeatureFlagService) update(userID int, fn func([]sysconfig.FeatureFlag) ([]sysconfig.FeatureFlag, error)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	flags, err := sysconfig.LoadFeatureFlags(s.db)
+	if err != nil {
+		return err
+	}
+	if flags, err = fn(flags); err != nil {
+		return err
+	}
+	if err := sysconfig.SaveFeatureFlags(s.db, flags, userID); err != nil {
+		return err
+	}
+	invalidateFeatureFlagCache()
+	return nil
+}
+
+// Enabled reports whether a flag is on for a user. A flag is on when it is
+// enabled and the user is in one of its groups or falls into its rollout
+// percentage. Unknown flags are off; userID 0 (no user) only gets flags
+// rolled out to 100%.
+func (s *FeatureFlagService) Enabled(ctx context.Context, key string, userID int) (bool, error) {
+	flags, err := s.cachedFlags()
+	if err != nil {
+		return false, err
+	}
+	i := featureFlagIndex(flags, key)
+	if i < 0 || !flags[i].Enabled {
+		return false, nil
+	}
+	f := flags[i]
+	if f.Percentage >= 100 {
+		return true, nil
+	}
+	if userID <= 0 {
+		return false, nil
+	}
+	if featureFlagBucket(f.Key, userID) < f.Percentage {
+		return true, nil
+	}
+	if len(f.Groups) == 0 {
+		return false, nil
+	}
+	groups, err := s.userGroups(uint(userID))
+	if err != nil {
+		return false, err
+	}
+	for _, g := range groups {
+		if slices.Contains(f.Groups, g) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// cachedFlags returns the flags, reloading them at most every
+// featureFlagCacheTTL.
+func (s *FeatureFlagService) cachedFlags() ([]sysconfig.FeatureFlag, error) {
+	featureFlagCache.Lock()
+	defer featureFlagCache.Unlock()
+	if s.now().Before(featureFlagCache.expires) {
+		return featureFlagCache.flags, nil
+	}
+	flags, err := sysconfig.LoadFeatureFlags(s.db)
+	if err != nil {
+		return nil, err
+	}
+	featureFlagCache.flags = flags
+	featureFlagCache.expires = s.now().Add(featureFlagCacheTTL)
+	return flags, nil
+}
+
+// featureFlagBucket maps a user to a stable bucket 0-99 per flag, so raising
+// a flag's percentage only adds users and different flags pick different
+// users.
+func featureFlagBucket(key string, userID int) int {
+	h := fnv.New32a()
+	fmt.Fprintf(h, "%s:%d", key, userID)
+	return int(h.Sum32() % 100)
+}
+
+func featureFlagIndex(flags []sysconfig.FeatureFlag, key string) int {
+	return slices.IndexFunc(flags, func(f sysconfig.FeatureFlag) bool { return f.Key == key })
+}
+
+// normalizeFeatureFlag trims and validates a flag.
+func normalizeFeatureFlag(f *sysconfig.FeatureFlag) error {
+	f.Key = strings.TrimSpace(f.Key)
+	f.Description = strings.TrimSpace(f.Description)
+	switch {
+	case !featureFlagKey.MatchString(f.Key) || len(f.Key) > 100:
+		return fmt.Errorf("%w: key must be lowercase letters, digits, '.', '_' or '-' and at most 100 characters", ErrFeatureFlagInvalid)
+	case len(f.Description) > 500:
+		return fmt.Errorf("%w: description is longer than 500 characters", ErrFeatureFlagInvalid)
+	case f.Percentage < 0 || f.Percentage > 100:
+		return fmt.Errorf("%w: percentage must be between 0 and 100", ErrFeatureFlagInvalid)
+	}
+	var groups []string
+	for _, g := range f.Groups {
+		if g = strings.TrimSpace(g); g != "" && !slices.Contains(groups, g) {
+			groups = append(groups, g)
+		}
+	}
+	f.Groups = groups
+	return nil
+}
@@ -0,0 +1,142 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/sysconfig"
+	"github.com/goatkit/goatflow/internal/testutil"
+)
+
+func TestFeatureFlagService(t *testing.T) {
+	db := testutil.UseMigratedDB(t)
+	ctx := context.Background()
+	invalidateFeatureFlagCache()
+	t.Cleanup(invalidateFeatureFlagCache)
+	for _, q := range []string{
+		`INSERT INTO users (id, login, pw, first_name, last_name, valid_id, create_time, create_by, change_time, change_by)
+			VALUES (50, 'tester', '', 'Test', 'Er', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+		`INSERT INTO groups (id, name, valid_id, create_time, create_by, change_time, change_by)
+			VALUES (50, 'beta-testers', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+		`INSERT INTO group_user (user_id, group_id, permission_key, create_time, create_by, change_time, change_by)
+			VALUES (50, 50, 'rw', CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+	} {
+		_, err := db.Exec(q)
+		require.NoError(t, err)
+	}
+	s := NewFeatureFlagService(db)
+
+	flags, err := s.List(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, flags)
+
+	_, err = s.Create(ctx, sysconfig.FeatureFlag{Key: "New Editor"}, 1)
+	assert.ErrorIs(t, err, ErrFeatureFlagInvalid)
+	_, err = s.Create(ctx, sysconfig.FeatureFlag{Key: "editor", Percentage: 101}, 1)
+	assert.ErrorIs(t, err, ErrFeatureFlagInvalid)
+
+	f, err := s.Create(ctx, sysconfig.FeatureFlag{Key: " new-editor ", Description: "Rich text editor",
+		Groups: []string{"beta-testers", " beta-testers ", ""}}, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "new-editor", f.Key)
+	assert.Equal(t, []string{"beta-testers"}, f.Groups)
+	_, err = s.Create(ctx, sysconfig.FeatureFlag{Key: "new-editor"}, 1)
+	assert.ErrorIs(t, err, ErrFeatureFlagExists)
+	_, err = s.Create(ctx, sysconfig.FeatureFlag{Key: "bulk.merge", Enabled: true, Percentage: 100}, 1)
+	require.NoError(t, err)
+
+	flags, err = s.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, flags, 2)
+	assert.Equal(t, "bulk.merge", flags[0].Key)
+
+	on, err := s.Enabled(ctx, "bulk.merge", 0)
+	require.NoError(t, err)
+	assert.True(t, on, "a flag at 100% is on even without a user")
+	on, err = s.Enabled(ctx, "new-editor", 50)
+	require.NoError(t, err)
+	assert.False(t, on, "disabled flags are off for their groups too")
+	on, err = s.Enabled(ctx, "unknown", 1)
+	require.NoError(t, err)
+	assert.False(t, on)
+
+	_, err = s.SetEnabled(ctx, "new-editor", true, 1)
+	require.NoError(t, err)
+	on, err = s.Enabled(ctx, "new-editor", 50)
+	require.NoError(t, err)
+	assert.True(t, on)
+	on, err = s.Enabled(ctx, "new-editor", 1)
+	require.NoError(t, err)
+	assert.False(t, on, "users outside the groups need the percentage")
+
+	f, err = s.Update(ctx, "new-editor", sysconfig.FeatureFlag{Key: "renamed", Enabled: true, Percentage: 50}, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "new-editor", f.Key, "the key cannot be changed")
+	assert.Empty(t, f.Groups)
+	_, err = s.Update(ctx, "missing", sysconfig.FeatureFlag{}, 1)
+	assert.ErrorIs(t, err, ErrFeatureFlagNotFound)
+
+	require.NoError(t, s.Delete(ctx, "new-editor", 1))
+	assert.ErrorIs(t, s.Delete(ctx, "new-editor", 1), ErrFeatureFlagNotFound)
+	_, err = s.Get(ctx, "new-editor")
+	assert.ErrorIs(t, err, ErrFeatureFlagNotFound)
+}
+
+func TestFeatureFlagServiceEnabledTargeting(t *testing.T) {
+	invalidateFeatureFlagCache()
+	t.Cleanup(invalidateFeatureFlagCache)
+	now := time.Now()
+	featureFlagCache.flags = []sysconfig.FeatureFlag{
+		{Key: "half", Enabled: true, Percentage: 50},
+		{Key: "beta", Enabled: true, Groups: []string{"beta-testers"}},
+	}
+	featureFlagCache.expires = now.Add(time.Minute)
+	s := &FeatureFlagService{
+		userGroups: func(userID uint) ([]string, error) {
+			if userID == 7 {
+				return []string{"users", "beta-testers"}, nil
+			}
+			return []string{"users"}, nil
+		},
+		now: func() time.Time { return now },
+	}
+	ctx := context.Background()
+
+	on := 0
+	for id := 1; id <= 1000; id++ {
+		enabled, err := s.Enabled(ctx, "half", id)
+		require.NoError(t, err)
+		again, _ := s.Enabled(ctx, "half", id)
+		require.Equal(t, enabled, again, "evaluation must be stable per user")
+		if enabled {
+			on++
+		}
+	}
+	assert.InDelta(t, 500, on, 75, "about half the users get a 50%% flag")
+
+	enabled, err := s.Enabled(ctx, "half", 0)
+	require.NoError(t, err)
+	assert.False(t, enabled, "without a user only 100% flags are on")
+
+	enabled, err = s.Enabled(ctx, "beta", 7)
+	require.NoError(t, err)
+	assert.True(t, enabled)
+	enabled, err = s.Enabled(ctx, "beta", 8)
+	require.NoError(t, err)
+	assert.False(t, enabled)
+}
+
+func TestFeatureFlagBucket(t *testing.T) {
+	for id := 1; id <= 200; id++ {
+		b := featureFlagBucket("rollout", id)
+		require.True(t, b >= 0 && b < 100, "bucket %d out of range", b)
+	}
+	assert.NotEqual(t,
+		[]int{featureFlagBucket("a", 1), featureFlagBucket("a", 2), featureFlagBucket("a", 3)},
+		[]int{featureFlagBucket("b", 1), featureFlagBucket("b", 2), featureFlagBucket("b", 3)},
+		"different flags pick different users")
+}
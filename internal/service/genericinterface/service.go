@@ -12,6 +12,7 @@ import (
 
 	"github.com/goatkit/goatflow/internal/models"
 	"github.com/goatkit/goatflow/internal/repository"
+	"github.com/goatkit/goatflow/internal/tracing"
 )
 
 // Transport defines the interface for HTTP transports (REST, SOAP).
@@ -117,7 +118,10 @@ func (s *Service) GetTransport(transportType string) (Transport, error) {
 
 // Invoke executes an invoker on a webservice.
 // This is the main method for making outbound requests.
-func (s *Service) Invoke(ctx context.Context, webserviceName, invokerName string, data map[string]interface{}) (*Response, error) {
+func (s *Service) Invoke(ctx context.Context, webserviceName, invokerName string, data map[string]interface{}) (_ *Response, err error) {
+	ctx, span := startInvokeSpan(ctx, webserviceName, invokerName)
+	defer func() { span.Finish(err) }()
+
 	// Get webservice config
 	ws, err := s.getWebserviceByName(ctx, webserviceName)
 	if err != nil {
@@ -180,7 +184,10 @@ func (s *Service) Invoke(ctx context.Context, webserviceName, invokerName string
 
 // InvokeWithController executes an invoker with specific controller/path settings.
 // Used for REST APIs where the path may vary based on parameters.
-func (s *Service) InvokeWithController(ctx context.Context, webserviceName, invokerName string, controller string, method string, data map[string]interface{}) (*Response, error) {
+func (s *Service) InvokeWithController(ctx context.Context, webserviceName, invokerName string, controller string, method string, data map[string]interface{}) (_ *Response, err error) {
+	ctx, span := startInvokeSpan(ctx, webserviceName, invokerName)
+	defer func() { span.Finish(err) }()
+
 	// Get webservice config
 	ws, err := s.getWebserviceByName(ctx, webserviceName)
 	if err != nil {
@@ -239,6 +246,15 @@ func (s *Service) InvokeWithController(ctx context.Context, webserviceName, invo
 	return response, nil
 }
 
+// startInvokeSpan starts the span covering an outbound invoker call, from
+// mapping through the HTTP request, which the transport records as a child.
+func startInvokeSpan(ctx context.Context, webserviceName, invokerName string) (context.Context, *tracing.Span) {
+	return tracing.Start(ctx, "genericinterface "+webserviceName+"."+invokerName,
+		tracing.String("genericinterface.webservice", webserviceName),
+		tracing.String("genericinterface.invoker", invokerName),
+	)
+}
+
 // GetWebservice returns a webservice configuration by name.
 func (s *Service) GetWebservice(ctx context.Context, name string) (*models.WebserviceConfig, error) {
 	return s.getWebserviceByName(ctx, name)
@@ -14,6 +14,7 @@ import (
 	"time"
 
 	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/tracing"
 )
 
 // RESTTransport implements the Transport interface for HTTP REST APIs.
@@ -25,7 +26,8 @@ type RESTTransport struct {
 func NewRESTTransport() *RESTTransport {
 	return &RESTTransport{
 		client: &http.Client{
-			Timeout: 30 * time.Second,
+			Timeout:   30 * time.Second,
+			Transport: tracing.NewTransport(nil),
 		},
 	}
 }
@@ -310,7 +312,7 @@ func (t *RESTTransport) TestConnection(ctx context.Context, config models.Transp
 	}
 
 	// Use a short timeout for connection test
-	client := &http.Client{Timeout: 10 * time.Second}
+	client := &http.Client{Timeout: 10 * time.Second, Transport: tracing.NewTransport(nil)}
 	resp, err := client.Do(req)
 	if err != nil {
 		return fmt.Errorf("connection failed: %w", err)
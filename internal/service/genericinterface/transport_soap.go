@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/tracing"
 )
 
 // SOAPTransport implements the Transport interface for SOAP web services.
@@ -22,7 +23,8 @@ type SOAPTransport struct {
 func NewSOAPTransport() *SOAPTransport {
 	return &SOAPTransport{
 		client: &http.Client{
-			Timeout: 30 * time.Second,
+			Timeout:   30 * time.Second,
+			Transport: tracing.NewTransport(nil),
 		},
 	}
 }
@@ -352,7 +354,7 @@ func (t *SOAPTransport) TestConnection(ctx context.Context, config models.Transp
 		return fmt.Errorf("failed to apply authentication: %w", err)
 	}
 
-	client := &http.Client{Timeout: 10 * time.Second}
+	client := &http.Client{Timeout: 10 * time.Second, Transport: tracing.NewTransport(nil)}
 	resp, err := client.Do(req)
 	if err != nil {
 		return fmt.Errorf("connection failed: %w", err)
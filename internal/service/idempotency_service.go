@@ -0,0 +1,137 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/goatkit/goatflow/internal/database"
+)
+
+// DefaultIdempotencyTTL is how long a stored response is replayed for when
+// server.idempotency.ttl is not set.
+const DefaultIdempotencyTTL = 24 * time.Hour
+
+// Errors returned by IdempotencyService.
+var (
+	ErrIdempotencyInProgress = errors.New("a request with this idempotency key is still being processed")
+	ErrIdempotencyKeyReused  = errors.New("idempotency key was already used for a different request")
+)
+
+// IdempotentResponse is a stored response snapshot.
+type IdempotentResponse struct {
+	StatusCode  int
+	ContentType string
+	Body        []byte
+}
+
+// IdempotencyService stores the responses of mutating API requests under the
+// client's Idempotency-Key, so a retried request gets the first response
+// instead of being executed twice. Keys are scoped to a principal (the API
+// token or the signed-in user) and expire after the TTL.
+type IdempotencyService struct {
+	db  *sql.DB
+	ttl time.Duration
+	now func() time.Time
+}
+
+// NewIdempotencyService creates an idempotency service. A ttl of zero uses
+// DefaultIdempotencyTTL.
+func NewIdempotencyService(db *sql.DB, ttl time.Duration) *IdempotencyService {
+	if ttl <= 0 {
+		ttl = DefaultIdempotencyTTL
+	}
+	return &IdempotencyService{db: db, ttl: ttl, now: time.Now}
+}
+
+// Begin reserves the key for a request. It returns nil when the caller should
+// run the request and then Complete or Release the key, and the stored
+// response when the same request already completed. A key that is still
+// reserved returns ErrIdempotencyInProgress; a key used for a different
+// method, path or body returns ErrIdempotencyKeyReused.
+func (s *IdempotencyService) Begin(ctx context.Context, principal, key, method, path, requestHash string) (*IdempotentResponse, error) {
+	// Two attempts: the second runs after an expired entry was removed.
+	for attempt := 0; attempt < 2; attempt++ {
+		now := s.now()
+		_, insertErr := s.db.ExecContext(ctx, database.ConvertPlaceholders(`
+			INSERT INTO api_idempotency_key (principal, idem_key, request_hash, method, path, status_code,
+				create_time, expires_time)
+			VALUES (?, ?, ?, ?, ?, 0, ?, ?)
+		`), principal, key, requestHash, method, path, now, now.Add(s.ttl))
+		if insertErr == nil {
+			return nil, nil
+		}
+
+		var hash string
+		var status int
+		var contentType, body sql.NullString
+		var expires time.Time
+		err := s.db.QueryRowContext(ctx, database.ConvertPlaceholders(`
+			SELECT request_hash, status_code, content_type, response_body, expires_time
+			FROM api_idempotency_key
+			WHERE principal = ? AND idem_key = ?
+		`), principal, key).Scan(&hash, &status, &contentType, &body, &expires)
+		if errors.Is(err, sql.ErrNoRows) {
+			// The insert failed for another reason than a duplicate key.
+			return nil, fmt.Errorf("reserve idempotency key: %w", insertErr)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("load idempotency key: %w", err)
+		}
+
+		if !now.Before(expires) {
+			if _, err := s.db.ExecContext(ctx, database.ConvertPlaceholders(
+				"DELETE FROM api_idempotency_key WHERE principal = ? AND idem_key = ? AND expires_time <= ?",
+			), principal, key, now); err != nil {
+				return nil, fmt.Errorf("remove expired idempotency key: %w", err)
+			}
+			continue
+		}
+		if hash != requestHash {
+			return nil, ErrIdempotencyKeyReused
+		}
+		if status == 0 {
+			return nil, ErrIdempotencyInProgress
+		}
+		return &IdempotentResponse{StatusCode: status, ContentType: contentType.String, Body: []byte(body.String)}, nil
+	}
+	return nil, ErrIdempotencyInProgress
+}
+
+// Complete stores the response of a request reserved with Begin.
+func (s *IdempotencyService) Complete(ctx context.Context, principal, key string, resp IdempotentResponse) error {
+	_, err := s.db.ExecContext(ctx, database.ConvertPlaceholders(`
+		UPDATE api_idempotency_key
+		SET status_code = ?, content_type = ?, response_body = ?
+		WHERE principal = ? AND idem_key = ?
+	`), resp.StatusCode, resp.ContentType, string(resp.Body), principal, key)
+	if err != nil {
+		return fmt.Errorf("store idempotent response: %w", err)
+	}
+	return nil
+}
+
+// Release removes a reservation without storing a response, so the request
+// can be retried with the same key.
+func (s *IdempotencyService) Release(ctx context.Context, principal, key string) error {
+	_, err := s.db.ExecContext(ctx, database.ConvertPlaceholders(
+		"DELETE FROM api_idempotency_key WHERE principal = ? AND idem_key = ? AND status_code = 0",
+	), principal, key)
+	if err != nil {
+		return fmt.Errorf("release idempotency key: %w", err)
+	}
+	return nil
+}
+
+// PurgeExpired removes expired keys and returns how many were removed.
+func (s *IdempotencyService) PurgeExpired(ctx context.Context) (int64, error) {
+	result, err := s.db.ExecContext(ctx, database.ConvertPlaceholders(
+		"DELETE FROM api_idempotency_key WHERE expires_time <= ?",
+	), s.now())
+	if err != nil {
+		return 0, fmt.Errorf("purge idempotency keys: %w", err)
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/testutil"
+)
+
+func newIdempotencyTestService(t *testing.T) (*IdempotencyService, *time.Time) {
+	t.Helper()
+	now := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	svc := NewIdempotencyService(testutil.MigratedDB(t), time.Hour)
+	svc.now = func() time.Time { return now }
+	return svc, &now
+}
+
+func TestIdempotencyService_ReplaysCompletedResponse(t *testing.T) {
+	svc, now := newIdempotencyTestService(t)
+	ctx := context.Background()
+
+	resp, err := svc.Begin(ctx, "token:1", "k1", "POST", "/api/v1/tickets", "hash-a")
+	require.NoError(t, err)
+	assert.Nil(t, resp, "a new key is reserved")
+
+	_, err = svc.Begin(ctx, "token:1", "k1", "POST", "/api/v1/tickets", "hash-a")
+	assert.ErrorIs(t, err, ErrIdempotencyInProgress)
+
+	require.NoError(t, svc.Complete(ctx, "token:1", "k1", IdempotentResponse{
+		StatusCode: 201, ContentType: "application/json", Body: []byte(`{"id":7}`),
+	}))
+
+	resp, err = svc.Begin(ctx, "token:1", "k1", "POST", "/api/v1/tickets", "hash-a")
+	require.NoError(t, err)
+	require.NotNil(t, resp)
+	assert.Equal(t, 201, resp.StatusCode)
+	assert.Equal(t, "application/json", resp.ContentType)
+	assert.JSONEq(t, `{"id":7}`, string(resp.Body))
+
+	_, err = svc.Begin(ctx, "token:1", "k1", "POST", "/api/v1/tickets", "hash-b")
+	assert.ErrorIs(t, err, ErrIdempotencyKeyReused)
+
+	resp, err = svc.Begin(ctx, "token:2", "k1", "POST", "/api/v1/tickets", "hash-b")
+	require.NoError(t, err)
+	assert.Nil(t, resp, "keys are scoped to the principal")
+
+	// After the TTL the key can be used again, even for another request.
+	*now = now.Add(time.Hour)
+	resp, err = svc.Begin(ctx, "token:1", "k1", "POST", "/api/v1/tickets", "hash-b")
+	require.NoError(t, err)
+	assert.Nil(t, resp)
+}
+
+func TestIdempotencyService_ReleaseAndPurge(t *testing.T) {
+	svc, now := newIdempotencyTestService(t)
+	ctx := context.Background()
+
+	_, err := svc.Begin(ctx, "user:5", "k1", "POST", "/api/v1/tickets", "hash-a")
+	require.NoError(t, err)
+	require.NoError(t, svc.Release(ctx, "user:5", "k1"))
+	resp, err := svc.Begin(ctx, "user:5", "k1", "POST", "/api/v1/tickets", "hash-a")
+	require.NoError(t, err)
+	assert.Nil(t, resp, "a released key is reserved again")
+
+	require.NoError(t, svc.Complete(ctx, "user:5", "k1", IdempotentResponse{StatusCode: 201}))
+	require.NoError(t, svc.Release(ctx, "user:5", "k1"))
+	resp, err = svc.Begin(ctx, "user:5", "k1", "POST", "/api/v1/tickets", "hash-a")
+	require.NoError(t, err)
+	require.NotNil(t, resp, "release never drops a stored response")
+
+	n, err := svc.PurgeExpired(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, n)
+	*now = now.Add(2 * time.Hour)
+	n, err = svc.PurgeExpired(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+}
@@ -0,0 +1,286 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/repository"
+)
+
+// Errors returned by KBService.
+var (
+	ErrKBArticleNotFound      = errors.New("knowledge base article not found")
+	ErrKBCategoryNotFound     = errors.New("knowledge base category not found")
+	ErrKBInvalidVisibility    = errors.New("visibility must be one of internal, customer, public")
+	ErrKBTitleRequired        = errors.New("title is required")
+	ErrKBCategoryNameRequired = errors.New("category name is required")
+)
+
+const (
+	// kbMaxSuggestionTerms caps the number of distinct terms taken from a ticket.
+	kbMaxSuggestionTerms = 12
+	// kbSuggestionCandidates is how many articles are scored per term query.
+	kbSuggestionCandidates = 25
+)
+
+// kbStopwords are dropped when extracting search terms from ticket text.
+var kbStopwords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true, "be": true,
+	"but": true, "by": true, "can": true, "do": true, "does": true, "for": true, "from": true,
+	"have": true, "hello": true, "hi": true, "how": true, "i": true, "if": true, "in": true,
+	"is": true, "it": true, "me": true, "my": true, "no": true, "not": true, "of": true,
+	"on": true, "or": true, "please": true, "re": true, "so": true, "thanks": true, "that": true,
+	"the": true, "this": true, "to": true, "was": true, "we": true, "what": true, "when": true,
+	"with": true, "you": true, "your": true, "fwd": true, "fw": true, "regards": true,
+}
+
+// KBService handles knowledge base business logic.
+type KBService struct {
+	repo *repository.KBRepository
+}
+
+// NewKBService creates a new knowledge base service.
+func NewKBService(repo *repository.KBRepository) *KBService {
+	return &KBService{repo: repo}
+}
+
+// ListCategories returns all valid categories.
+func (s *KBService) ListCategories(ctx context.Context) ([]models.KBCategory, error) {
+	return s.repo.ListCategories(ctx)
+}
+
+// CreateCategory validates and stores a new category.
+func (s *KBService) CreateCategory(ctx context.Context, cat *models.KBCategory) error {
+	cat.Name = strings.TrimSpace(cat.Name)
+	if cat.Name == "" {
+		return ErrKBCategoryNameRequired
+	}
+	if cat.ParentID != nil {
+		ok, err := s.repo.CategoryExists(ctx, *cat.ParentID)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return ErrKBCategoryNotFound
+		}
+	}
+	id, err := s.repo.CreateCategory(ctx, cat)
+	if err != nil {
+		return err
+	}
+	cat.ID = id
+	return nil
+}
+
+// GetArticle returns an article if it is visible at one of the given
+// visibilities (all visibilities when none are given).
+func (s *KBService) GetArticle(ctx context.Context, id int, visibilities []models.KBVisibility) (*models.KBArticle, error) {
+	article, err := s.repo.GetArticle(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if article == nil || !kbVisibleAt(article.Visibility, visibilities) {
+		return nil, ErrKBArticleNotFound
+	}
+	return article, nil
+}
+
+// ListArticles lists or searches articles.
+func (s *KBService) ListArticles(ctx context.Context, filter models.KBArticleFilter) ([]models.KBArticle, error) {
+	return s.repo.ListArticles(ctx, filter)
+}
+
+// CreateArticle validates and stores a new article as version 1.
+func (s *KBService) CreateArticle(ctx context.Context, article *models.KBArticle, changeNote string) error {
+	if err := s.validateArticle(ctx, article); err != nil {
+		return err
+	}
+	_, err := s.repo.CreateArticle(ctx, article, changeNote)
+	return err
+}
+
+// UpdateArticle validates and stores a new version of an existing article.
+func (s *KBService) UpdateArticle(ctx context.Context, article *models.KBArticle, changeNote string, userID int) error {
+	existing, err := s.repo.GetArticle(ctx, article.ID)
+	if err != nil {
+		return err
+	}
+	if existing == nil {
+		return ErrKBArticleNotFound
+	}
+	if err := s.validateArticle(ctx, article); err != nil {
+		return err
+	}
+	return s.repo.UpdateArticle(ctx, article, changeNote, userID)
+}
+
+// DeleteArticle invalidates an article. Its versions are kept.
+func (s *KBService) DeleteArticle(ctx context.Context, id int, userID int) error {
+	existing, err := s.repo.GetArticle(ctx, id)
+	if err != nil {
+		return err
+	}
+	if existing == nil {
+		return ErrKBArticleNotFound
+	}
+	return s.repo.InvalidateArticle(ctx, id, userID)
+}
+
+// ListVersions returns the revision history of an article.
+func (s *KBService) ListVersions(ctx context.Context, articleID int) ([]models.KBArticleVersion, error) {
+	existing, err := s.repo.GetArticle(ctx, articleID)
+	if err != nil {
+		return nil, err
+	}
+	if existing == nil {
+		return nil, ErrKBArticleNotFound
+	}
+	return s.repo.ListVersions(ctx, articleID)
+}
+
+// SuggestArticles proposes articles matching the given ticket subject and body.
+// Candidates are collected per extracted term and ranked by ScoreKBArticle.
+func (s *KBService) SuggestArticles(ctx context.Context, subject, body string, visibilities []models.KBVisibility, limit int) ([]models.KBSuggestion, error) {
+	terms := ExtractKBTerms(subject+" "+body, kbMaxSuggestionTerms)
+	if len(terms) == 0 {
+		return []models.KBSuggestion{}, nil
+	}
+	if limit <= 0 {
+		limit = 5
+	}
+
+	candidates := make(map[int]models.KBArticle)
+	for _, term := range terms {
+		articles, err := s.repo.ListArticles(ctx, models.KBArticleFilter{
+			Query:        term,
+			Visibilities: visibilities,
+			Limit:        kbSuggestionCandidates,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, a := range articles {
+			candidates[a.ID] = a
+		}
+	}
+
+	suggestions := make([]models.KBSuggestion, 0, len(candidates))
+	for _, a := range candidates {
+		score, matched := ScoreKBArticle(a, terms)
+		if score > 0 {
+			suggestions = append(suggestions, models.KBSuggestion{Article: a, Score: score, Matched: matched})
+		}
+	}
+	sort.Slice(suggestions, func(i, j int) bool {
+		if suggestions[i].Score != suggestions[j].Score {
+			return suggestions[i].Score > suggestions[j].Score
+		}
+		return suggestions[i].Article.ID < suggestions[j].Article.ID
+	})
+	if len(suggestions) > limit {
+		suggestions = suggestions[:limit]
+	}
+	return suggestions, nil
+}
+
+func (s *KBService) validateArticle(ctx context.Context, article *models.KBArticle) error {
+	article.Title = strings.TrimSpace(article.Title)
+	if article.Title == "" {
+		return ErrKBTitleRequired
+	}
+	if article.Visibility == "" {
+		article.Visibility = models.KBVisibilityInternal
+	}
+	if !article.Visibility.IsValid() {
+		return ErrKBInvalidVisibility
+	}
+	ok, err := s.repo.CategoryExists(ctx, article.CategoryID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrKBCategoryNotFound
+	}
+	return nil
+}
+
+// ExtractKBTerms returns up to limit distinct lower-cased search terms from
+// text, in order of first appearance. Stopwords, numbers and words shorter
+// than three characters are skipped.
+func ExtractKBTerms(text string, limit int) []string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	seen := make(map[string]bool)
+	terms := make([]string, 0, limit)
+	for _, w := range words {
+		if len([]rune(w)) < 3 || kbStopwords[w] || seen[w] || isAllDigits(w) {
+			continue
+		}
+		seen[w] = true
+		terms = append(terms, w)
+		if len(terms) == limit {
+			break
+		}
+	}
+	return terms
+}
+
+// ScoreKBArticle scores an article against search terms. A match in the
+// title counts most, then keywords, then body. It returns the score and the
+// terms that matched.
+func ScoreKBArticle(article models.KBArticle, terms []string) (float64, []string) {
+	title := strings.ToLower(article.Title)
+	keywords := strings.ToLower(article.Keywords)
+	body := strings.ToLower(article.Body)
+
+	var score float64
+	matched := make([]string, 0)
+	for _, term := range terms {
+		var termScore float64
+		if strings.Contains(title, term) {
+			termScore += 3
+		}
+		if strings.Contains(keywords, term) {
+			termScore += 2
+		}
+		if strings.Contains(body, term) {
+			termScore++
+		}
+		if termScore > 0 {
+			score += termScore
+			matched = append(matched, term)
+		}
+	}
+	if len(terms) > 0 {
+		// Favour articles covering more of the ticket's terms.
+		score *= 1 + float64(len(matched))/float64(len(terms))
+	}
+	return score, matched
+}
+
+func kbVisibleAt(v models.KBVisibility, allowed []models.KBVisibility) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	for _, a := range allowed {
+		if a == v {
+			return true
+		}
+	}
+	return false
+}
+
+func isAllDigits(s string) bool {
+	for _, r := range s {
+		if !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,46 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/goatkit/goatflow/internal/models"
+)
+
+func TestExtractKBTerms(t *testing.T) {
+	terms := ExtractKBTerms("Re: Hi, my VPN client won't connect to the VPN after update 42", 10)
+	assert.Equal(t, []string{"vpn", "client", "won", "connect", "after", "update"}, terms)
+
+	assert.Len(t, ExtractKBTerms("printer scanner monitor keyboard mouse", 3), 3)
+	assert.Empty(t, ExtractKBTerms("hi, is it me?", 10))
+}
+
+func TestScoreKBArticle(t *testing.T) {
+	titleMatch := models.KBArticle{Title: "Resetting your VPN password", Body: "Open the portal."}
+	bodyMatch := models.KBArticle{Title: "Network basics", Body: "Some VPN notes."}
+	noMatch := models.KBArticle{Title: "Printer setup", Body: "Install the driver."}
+	terms := []string{"vpn", "password"}
+
+	titleScore, matched := ScoreKBArticle(titleMatch, terms)
+	assert.Equal(t, []string{"vpn", "password"}, matched)
+
+	bodyScore, matched := ScoreKBArticle(bodyMatch, terms)
+	assert.Equal(t, []string{"vpn"}, matched)
+	assert.Greater(t, titleScore, bodyScore)
+
+	score, matched := ScoreKBArticle(noMatch, terms)
+	assert.Zero(t, score)
+	assert.Empty(t, matched)
+
+	withKeywords := models.KBArticle{Title: "Network basics", Keywords: "vpn", Body: "Some VPN notes."}
+	keywordScore, _ := ScoreKBArticle(withKeywords, terms)
+	assert.Greater(t, keywordScore, bodyScore)
+}
+
+func TestKBVisibleAt(t *testing.T) {
+	customerView := []models.KBVisibility{models.KBVisibilityCustomer, models.KBVisibilityPublic}
+	assert.True(t, kbVisibleAt(models.KBVisibilityPublic, customerView))
+	assert.False(t, kbVisibleAt(models.KBVisibilityInternal, customerView))
+	assert.True(t, kbVisibleAt(models.KBVisibilityInternal, nil))
+}
@@ -0,0 +1,291 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"regexp"
+	"slices"
+	"time"
+	"unicode/utf8"
+
+	"github.com/goatkit/goatflow/internal/i18n"
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/repository"
+)
+
+// Errors returned by LanguagePackService.
+var (
+	ErrLanguagePackInvalid  = errors.New("invalid language pack")
+	ErrLanguagePackNotFound = errors.New("language pack not found")
+)
+
+// Language pack file formats.
+const (
+	LanguagePackJSON = "json"
+	LanguagePackPO   = "po"
+)
+
+const (
+	// MaxLanguagePackSize limits uploaded language pack files.
+	MaxLanguagePackSize = 4 << 20
+
+	maxLanguagePackEntries  = 20000
+	maxLanguagePackKeyLen   = 200
+	maxLanguagePackValueLen = 10000
+
+	// LanguagePackReloadInterval is how often Watch checks for packs
+	// changed by other instances.
+	LanguagePackReloadInterval = 30 * time.Second
+)
+
+var (
+	languagePackLang = regexp.MustCompile(`^[a-z]{2,3}(-[A-Za-z0-9]{2,8})?$`)
+	languagePackKey  = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]*$`)
+
+	// languagePackVerb matches fmt verbs; translations must keep the
+	// placeholders of the English text.
+	languagePackVerb = regexp.MustCompile(`%[-+# 0-9.]*[a-zA-Z]`)
+)
+
+// LanguagePackImport is the result of an import.
+type LanguagePackImport struct {
+	Pack     *models.LanguagePack `json:"pack"`
+	Imported int                  `json:"imported"`
+	Warnings []string             `json:"warnings"`
+}
+
+// LanguagePackService manages the language packs imported at runtime and
+// applies them to the translations without a restart.
+type LanguagePackService struct {
+	repo *repository.LanguagePackRepository
+	i18n *i18n.I18n
+	now  func() time.Time
+}
+
+// NewLanguagePackService creates a language pack service applying packs to
+// the global translations.
+func NewLanguagePackService(db *sql.DB) *LanguagePackService {
+	return &LanguagePackService{
+		repo: repository.NewLanguagePackRepository(db),
+		i18n: i18n.GetInstance(),
+		now:  time.Now,
+	}
+}
+
+// List returns the stored packs, sorted by language.
+func (s *LanguagePackService) List(ctx context.Context) ([]*models.LanguagePack, error) {
+	return s.repo.List(ctx)
+}
+
+// Import parses a pack in the given format and stores it for lang. With
+// replace the pack replaces the language's previous pack; otherwise its
+// entries are merged into it. Entries whose placeholders differ from the
+// English text are imported with a warning.
+func (s *LanguagePackService) Import(ctx context.Context, lang, format string, data []byte, replace bool, userID int) (*LanguagePackImport, error) {
+	if !languagePackLang.MatchString(lang) {
+		return nil, fmt.Errorf("%w: language must be a code like \"de\" or \"pt-BR\"", ErrLanguagePackInvalid)
+	}
+	if len(data) > MaxLanguagePackSize {
+		return nil, fmt.Errorf("%w: file is larger than %d MB", ErrLanguagePackInvalid, MaxLanguagePackSize>>20)
+	}
+
+	var (
+		entries map[string]string
+		err     error
+	)
+	switch format {
+	case LanguagePackJSON:
+		entries, err = i18n.ParseJSONPack(data)
+	case LanguagePackPO:
+		entries, err = i18n.ParsePO(bytes.NewReader(data))
+	default:
+		return nil, fmt.Errorf("%w: format must be json or po", ErrLanguagePackInvalid)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrLanguagePackInvalid, err)
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("%w: no translations found", ErrLanguagePackInvalid)
+	}
+	for key, value := range entries {
+		switch {
+		case len(key) > maxLanguagePackKeyLen || !languagePackKey.MatchString(key):
+			return nil, fmt.Errorf("%w: invalid key %q", ErrLanguagePackInvalid, key)
+		case len(value) > maxLanguagePackValueLen || !utf8.ValidString(value):
+			return nil, fmt.Errorf("%w: invalid translation of %q", ErrLanguagePackInvalid, key)
+		}
+	}
+	result := &LanguagePackImport{Imported: len(entries), Warnings: s.placeholderWarnings(entries)}
+
+	if !replace {
+		existing, err := s.repo.Get(ctx, lang)
+		if err != nil {
+			return nil, err
+		}
+		if existing != nil {
+			merged := maps.Clone(existing.Entries)
+			maps.Copy(merged, entries)
+			entries = merged
+		}
+	}
+	if len(entries) > maxLanguagePackEntries {
+		return nil, fmt.Errorf("%w: more than %d translations", ErrLanguagePackInvalid, maxLanguagePackEntries)
+	}
+
+	checksum, err := languagePackChecksum(entries)
+	if err != nil {
+		return nil, err
+	}
+	pack := &models.LanguagePack{
+		Language:   lang,
+		EntryCount: len(entries),
+		Checksum:   checksum,
+		Entries:    entries,
+		CreateTime: s.now(),
+		CreateBy:   userID,
+	}
+	if err := s.repo.Save(ctx, pack); err != nil {
+		return nil, err
+	}
+	result.Pack = pack
+	return result, s.Reload(ctx)
+}
+
+// placeholderWarnings lists the entries whose fmt verbs differ from the
+// English text, which would garble the output at runtime.
+func (s *LanguagePackService) placeholderWarnings(entries map[string]string) []string {
+	source := i18n.FlattenTranslations(s.i18n.GetTranslations("en"))
+	warnings := []string{}
+	for _, key := range slices.Sorted(maps.Keys(entries)) {
+		en, ok := source[key]
+		if !ok {
+			continue
+		}
+		if want, got := len(languagePackVerb.FindAllString(en, -1)), len(languagePackVerb.FindAllString(entries[key], -1)); want != got {
+			warnings = append(warnings, fmt.Sprintf("%s: %d placeholder(s), the English text has %d", key, got, want))
+		}
+	}
+	return warnings
+}
+
+// Export returns the translations of lang in the given format: only its pack
+// (scope "pack") or the built-in translations with the pack applied (scope
+// "all"). PO files carry the English text as msgid for translators.
+func (s *LanguagePackService) Export(ctx context.Context, lang, format, scope string) ([]byte, error) {
+	var entries map[string]string
+	switch scope {
+	case "pack":
+		pack, err := s.repo.Get(ctx, lang)
+		if err != nil {
+			return nil, err
+		}
+		if pack == nil {
+			return nil, ErrLanguagePackNotFound
+		}
+		entries = pack.Entries
+	case "", "all":
+		entries = i18n.FlattenTranslations(s.i18n.GetTranslations(lang))
+		if len(entries) == 0 {
+			return nil, ErrLanguagePackNotFound
+		}
+	default:
+		return nil, fmt.Errorf("%w: scope must be pack or all", ErrLanguagePackInvalid)
+	}
+
+	switch format {
+	case LanguagePackJSON:
+		return json.MarshalIndent(i18n.UnflattenTranslations(entries), "", "  ")
+	case LanguagePackPO:
+		var buf bytes.Buffer
+		source := i18n.FlattenTranslations(s.i18n.GetTranslations("en"))
+		if err := i18n.WritePO(&buf, lang, entries, source); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+	return nil, fmt.Errorf("%w: format must be json or po", ErrLanguagePackInvalid)
+}
+
+// Delete removes the pack of lang; the language falls back to its built-in
+// translations, if any.
+func (s *LanguagePackService) Delete(ctx context.Context, lang string) error {
+	deleted, err := s.repo.Delete(ctx, lang)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrLanguagePackNotFound
+	}
+	return s.Reload(ctx)
+}
+
+// Reload applies the stored packs to the translations.
+func (s *LanguagePackService) Reload(ctx context.Context) error {
+	_, err := s.reload(ctx)
+	return err
+}
+
+func (s *LanguagePackService) reload(ctx context.Context) (map[string]string, error) {
+	list, err := s.repo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	packs := make(map[string]map[string]string, len(list))
+	sigs := make(map[string]string, len(list))
+	for _, p := range list {
+		packs[p.Language] = p.Entries
+		sigs[p.Language] = p.Checksum
+	}
+	s.i18n.SetLanguagePacks(packs)
+	return sigs, nil
+}
+
+// Watch applies the stored packs and then reloads them every interval when
+// they changed, so packs imported on another instance take effect without a
+// restart. It returns when ctx is done.
+func (s *LanguagePackService) Watch(ctx context.Context, interval time.Duration) {
+	loaded, err := s.reload(ctx)
+	if err != nil {
+		log.Printf("language packs: load failed: %v", err)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		sigs, err := s.repo.Signatures(ctx)
+		if err != nil {
+			log.Printf("language packs: checking for changes failed: %v", err)
+			continue
+		}
+		if loaded != nil && maps.Equal(sigs, loaded) {
+			continue
+		}
+		if loaded, err = s.reload(ctx); err != nil {
+			log.Printf("language packs: reload failed: %v", err)
+		}
+	}
+}
+
+// languagePackChecksum returns the SHA-256 of the pack's JSON encoding,
+// which has sorted keys.
+func languagePackChecksum(entries map[string]string) (string, error) {
+	content, err := json.Marshal(entries)
+	if err != nil {
+		return "", fmt.Errorf("encode language pack: %w", err)
+	}
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,111 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/i18n"
+	"github.com/goatkit/goatflow/internal/testutil"
+)
+
+func TestLanguagePackService(t *testing.T) {
+	db := testutil.UseMigratedDB(t)
+	ctx := context.Background()
+	tr := i18n.GetInstance()
+	t.Cleanup(func() { tr.SetLanguagePacks(nil) })
+	s := NewLanguagePackService(db)
+
+	_, err := s.Import(ctx, "German", LanguagePackJSON, []byte(`{"app": {"name": "x"}}`), false, 1)
+	assert.ErrorIs(t, err, ErrLanguagePackInvalid)
+	_, err = s.Import(ctx, "de", "xliff", []byte(`{}`), false, 1)
+	assert.ErrorIs(t, err, ErrLanguagePackInvalid)
+	_, err = s.Import(ctx, "de", LanguagePackJSON, []byte(`{"app": `), false, 1)
+	assert.ErrorIs(t, err, ErrLanguagePackInvalid)
+	_, err = s.Import(ctx, "de", LanguagePackJSON, []byte(`{}`), false, 1)
+	assert.ErrorIs(t, err, ErrLanguagePackInvalid)
+	_, err = s.Import(ctx, "de", LanguagePackJSON, []byte(`{"bad key": "x"}`), false, 1)
+	assert.ErrorIs(t, err, ErrLanguagePackInvalid)
+
+	res, err := s.Import(ctx, "de", LanguagePackJSON,
+		[]byte(`{"app": {"name": "Ziege"}, "validation.max_value": "Höchstens %d", "password.history": "Nicht wiederverwenden"}`), false, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 3, res.Imported)
+	assert.Equal(t, []string{"password.history: 0 placeholder(s), the English text has 1"}, res.Warnings)
+	assert.Equal(t, "Ziege", tr.T("de", "app.name"), "imports apply without a restart")
+	assert.Equal(t, "Höchstens 5", tr.T("de", "validation.max_value", 5))
+
+	po := "msgctxt \"app.name\"\nmsgid \"GoatFlow\"\nmsgstr \"Geiß\"\n\nmsgctxt \"tickets.count.few\"\nmsgid \"\"\nmsgstr \"%d tickets\"\n"
+	res, err = s.Import(ctx, "de", LanguagePackPO, []byte(po), false, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 4, res.Pack.EntryCount, "merge keeps the other entries")
+	assert.Equal(t, "Geiß", tr.T("de", "app.name"))
+
+	_, err = s.Import(ctx, "sv", LanguagePackJSON, []byte(`{"app.name": "Get"}`), true, 1)
+	require.NoError(t, err)
+	assert.Contains(t, tr.GetSupportedLanguages(), "sv")
+
+	packs, err := s.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, packs, 2)
+	assert.Equal(t, "de", packs[0].Language)
+
+	out, err := s.Export(ctx, "de", LanguagePackPO, "pack")
+	require.NoError(t, err)
+	parsed, err := i18n.ParsePO(strings.NewReader(string(out)))
+	require.NoError(t, err)
+	assert.Equal(t, "Geiß", parsed["app.name"])
+	assert.Len(t, parsed, 4)
+	assert.Contains(t, string(out), `msgid "GoatFlow"`, "PO exports carry the English text")
+
+	out, err = s.Export(ctx, "de", LanguagePackJSON, "all")
+	require.NoError(t, err)
+	all, err := i18n.ParseJSONPack(out)
+	require.NoError(t, err)
+	assert.Equal(t, "Geiß", all["app.name"])
+	assert.Greater(t, len(all), 100, "scope all includes the built-in translations")
+
+	_, err = s.Export(ctx, "fr", LanguagePackJSON, "pack")
+	assert.ErrorIs(t, err, ErrLanguagePackNotFound)
+
+	res, err = s.Import(ctx, "de", LanguagePackJSON, []byte(`{"app.name": "Bock"}`), true, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 1, res.Pack.EntryCount)
+	assert.Equal(t, "Bock", tr.T("de", "app.name"))
+	assert.Equal(t, "Maximalwert ist 5", tr.T("de", "validation.max_value", 5), "replace drops the other entries")
+
+	require.NoError(t, s.Delete(ctx, "sv"))
+	assert.ErrorIs(t, s.Delete(ctx, "sv"), ErrLanguagePackNotFound)
+	assert.NotContains(t, tr.GetSupportedLanguages(), "sv")
+}
+
+func TestLanguagePackServiceWatch(t *testing.T) {
+	db := testutil.UseMigratedDB(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	tr := i18n.GetInstance()
+	t.Cleanup(func() { tr.SetLanguagePacks(nil) })
+
+	// Another instance imports a pack; the watcher picks it up.
+	other := NewLanguagePackService(db)
+	_, err := other.Import(ctx, "nl", LanguagePackJSON, []byte(`{"app.name": "Geit"}`), true, 1)
+	require.NoError(t, err)
+	tr.SetLanguagePacks(nil)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		NewLanguagePackService(db).Watch(ctx, 10*time.Millisecond)
+	}()
+	assert.Eventually(t, func() bool { return tr.T("nl", "app.name") == "Geit" }, time.Second, 5*time.Millisecond)
+
+	_, err = other.repo.Delete(ctx, "nl")
+	require.NoError(t, err)
+	assert.Eventually(t, func() bool { return tr.T("nl", "app.name") != "Geit" }, time.Second, 5*time.Millisecond)
+
+	cancel()
+	<-done
+}
@@ -0,0 +1,294 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/ldap"
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/services"
+)
+
+// Errors returned by LDAPAccountService.
+var (
+	ErrLDAPUnknownAccount  = errors.New("no account exists for this directory user and auto_create is off")
+	ErrLDAPAccountDisabled = errors.New("the account for this directory user is disabled")
+)
+
+// LDAPAccountSyncResult summarises one directory sync run.
+type LDAPAccountSyncResult struct {
+	Directories int      `json:"directories"`
+	UsersFound  int      `json:"users_found"`
+	Created     int      `json:"created"`
+	Updated     int      `json:"updated"`
+	Skipped     int      `json:"skipped"`
+	Errors      []string `json:"errors,omitempty"`
+}
+
+// LDAPAccountService signs agents and customer users in against LDAP
+// directories and keeps their users/customer_user rows and mapped group
+// permissions in step with the directory.
+type LDAPAccountService struct {
+	db   *sql.DB
+	dirs ldap.Directories
+	now  func() time.Time
+}
+
+// NewLDAPAccountService creates an LDAP account service for the given directories.
+func NewLDAPAccountService(db *sql.DB, dirs ldap.Directories) *LDAPAccountService {
+	return &LDAPAccountService{db: db, dirs: dirs, now: time.Now}
+}
+
+// Enabled reports whether any directory serves the frontend.
+func (s *LDAPAccountService) Enabled(frontend string) bool {
+	return len(s.dirs.ForFrontend(frontend)) > 0
+}
+
+// AuthenticateAgent checks the password against the agent directories and
+// returns the matching agent's ID and login, creating or updating the agent.
+func (s *LDAPAccountService) AuthenticateAgent(ctx context.Context, username, password string) (int, string, error) {
+	dir, user, err := s.dirs.Authenticate(ldap.FrontendAgent, username, password)
+	if err != nil {
+		return 0, "", err
+	}
+	userID, _, err := s.syncAgent(ctx, dir, user)
+	if err != nil {
+		return 0, "", err
+	}
+	return userID, user.Username, nil
+}
+
+// AuthenticateCustomer checks the password against the customer directories
+// and returns the customer user's login, creating or updating it.
+func (s *LDAPAccountService) AuthenticateCustomer(ctx context.Context, username, password string) (string, error) {
+	dir, user, err := s.dirs.Authenticate(ldap.FrontendCustomer, username, password)
+	if err != nil {
+		return "", err
+	}
+	if _, err := s.syncCustomer(ctx, dir, user); err != nil {
+		return "", err
+	}
+	return user.Username, nil
+}
+
+// Sync copies attributes and group memberships of every user in the
+// directories that have sync enabled. Users without an account are created
+// only when the directory allows it. A failing directory is recorded in the
+// result and does not stop the others.
+func (s *LDAPAccountService) Sync(ctx context.Context) (*LDAPAccountSyncResult, error) {
+	result := &LDAPAccountSyncResult{}
+	for _, dir := range s.dirs {
+		if !dir.Sync {
+			continue
+		}
+		result.Directories++
+		users, err := dir.NewProvider().ListUsers()
+		if err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("%s: %v", dir.Name, err))
+			continue
+		}
+		result.UsersFound += len(users)
+		for _, user := range users {
+			if err := ctx.Err(); err != nil {
+				return result, err
+			}
+			var state accountChange
+			if dir.Frontend == ldap.FrontendCustomer {
+				state, err = s.syncCustomer(ctx, dir, user)
+			} else {
+				_, state, err = s.syncAgent(ctx, dir, user)
+			}
+			switch {
+			case errors.Is(err, ErrLDAPUnknownAccount), errors.Is(err, ErrLDAPAccountDisabled):
+				result.Skipped++
+			case err != nil:
+				result.Errors = append(result.Errors, fmt.Sprintf("%s: %s: %v", dir.Name, user.Username, err))
+			case state == accountCreated:
+				result.Created++
+			case state == accountUpdated:
+				result.Updated++
+			}
+		}
+	}
+	return result, nil
+}
+
+type accountChange int
+
+const (
+	accountUnchanged accountChange = iota
+	accountCreated
+	accountUpdated
+)
+
+// syncAgent finds or creates the agent for a directory user, refreshes the
+// name and applies the group mappings.
+func (s *LDAPAccountService) syncAgent(ctx context.Context, dir *ldap.Directory, user *ldap.User) (int, accountChange, error) {
+	first, last := ldapDisplayName(user)
+	now := s.now()
+	state := accountUnchanged
+
+	var userID, validID int
+	var curFirst, curLast string
+	err := s.db.QueryRowContext(ctx, database.ConvertPlaceholders(
+		"SELECT id, first_name, last_name, valid_id FROM users WHERE login = ?"), user.Username).
+		Scan(&userID, &curFirst, &curLast, &validID)
+	switch {
+	case err == sql.ErrNoRows:
+		if !dir.AutoCreate {
+			return 0, accountUnchanged, ErrLDAPUnknownAccount
+		}
+		pw, err := unusablePassword()
+		if err != nil {
+			return 0, accountUnchanged, err
+		}
+		query := database.ConvertPlaceholders(`
+			INSERT INTO users (login, pw, first_name, last_name, valid_id, create_time, create_by, change_time, change_by)
+			VALUES (?, ?, ?, ?, 1, ?, ?, ?, ?)
+			RETURNING id`)
+		id, err := database.GetAdapter().InsertWithReturning(s.db, query,
+			user.Username, pw, first, last, now, ssoSystemUserID, now, ssoSystemUserID)
+		if err != nil {
+			return 0, accountUnchanged, fmt.Errorf("provision agent: %w", err)
+		}
+		userID, state = int(id), accountCreated
+	case err != nil:
+		return 0, accountUnchanged, fmt.Errorf("look up agent: %w", err)
+	case validID != 1:
+		return 0, accountUnchanged, ErrLDAPAccountDisabled
+	case curFirst != first || curLast != last:
+		if _, err := s.db.ExecContext(ctx, database.ConvertPlaceholders(
+			"UPDATE users SET first_name = ?, last_name = ?, change_time = ?, change_by = ? WHERE id = ?"),
+			first, last, now, ssoSystemUserID, userID); err != nil {
+			return 0, accountUnchanged, fmt.Errorf("update agent: %w", err)
+		}
+		state = accountUpdated
+	}
+
+	if err := s.syncGroups(ctx, dir, user, "group_user", userID); err != nil {
+		return 0, state, err
+	}
+	services.InvalidateUserPermissions(userID)
+	return userID, state, nil
+}
+
+// syncCustomer finds or creates the customer user for a directory user,
+// refreshes name, email and customer ID and applies the group mappings.
+// Attributes the directory does not provide keep their stored values.
+func (s *LDAPAccountService) syncCustomer(ctx context.Context, dir *ldap.Directory, user *ldap.User) (accountChange, error) {
+	first, last := ldapDisplayName(user)
+	now := s.now()
+	state := accountUnchanged
+
+	var validID int
+	var email, customerID, curFirst, curLast string
+	err := s.db.QueryRowContext(ctx, database.ConvertPlaceholders(
+		"SELECT email, customer_id, first_name, last_name, valid_id FROM customer_user WHERE login = ?"), user.Username).
+		Scan(&email, &customerID, &curFirst, &curLast, &validID)
+	switch {
+	case err == sql.ErrNoRows:
+		if !dir.AutoCreate {
+			return accountUnchanged, ErrLDAPUnknownAccount
+		}
+		pw, err := unusablePassword()
+		if err != nil {
+			return accountUnchanged, err
+		}
+		customerID = user.CustomerID
+		if customerID == "" {
+			if at := strings.LastIndex(user.Email, "@"); at >= 0 {
+				customerID = user.Email[at+1:]
+			} else {
+				customerID = user.Username
+			}
+		}
+		if _, err := s.db.ExecContext(ctx, database.ConvertPlaceholders(`
+			INSERT INTO customer_user (login, email, customer_id, pw, first_name, last_name, valid_id,
+				create_time, create_by, change_time, change_by)
+			VALUES (?, ?, ?, ?, ?, ?, 1, ?, ?, ?, ?)`),
+			user.Username, user.Email, customerID, pw, first, last, now, ssoSystemUserID, now, ssoSystemUserID); err != nil {
+			return accountUnchanged, fmt.Errorf("provision customer user: %w", err)
+		}
+		state = accountCreated
+	case err != nil:
+		return accountUnchanged, fmt.Errorf("look up customer user: %w", err)
+	case validID != 1:
+		return accountUnchanged, ErrLDAPAccountDisabled
+	default:
+		newEmail, newCustomerID := email, customerID
+		if user.Email != "" {
+			newEmail = user.Email
+		}
+		if user.CustomerID != "" {
+			newCustomerID = user.CustomerID
+		}
+		if newEmail != email || newCustomerID != customerID || first != curFirst || last != curLast {
+			if _, err := s.db.ExecContext(ctx, database.ConvertPlaceholders(`
+				UPDATE customer_user SET email = ?, customer_id = ?, first_name = ?, last_name = ?,
+					change_time = ?, change_by = ?
+				WHERE login = ?`),
+				newEmail, newCustomerID, first, last, now, ssoSystemUserID, user.Username); err != nil {
+				return accountUnchanged, fmt.Errorf("update customer user: %w", err)
+			}
+			state = accountUpdated
+		}
+	}
+
+	if err := s.syncGroups(ctx, dir, user, "group_customer_user", user.Username); err != nil {
+		return state, err
+	}
+	services.InvalidateCustomerUserPermissions(user.Username)
+	return state, nil
+}
+
+// syncGroups applies the directory's group mappings. Mappings name GoatFlow
+// groups; a group that does not exist is logged and skipped.
+func (s *LDAPAccountService) syncGroups(ctx context.Context, dir *ldap.Directory, user *ldap.User, table string, userKey interface{}) error {
+	if len(dir.GroupMappings) == 0 {
+		return nil
+	}
+	mappings := make([]models.SSOGroupMapping, 0, len(dir.GroupMappings))
+	for _, m := range dir.GroupMappings {
+		var groupID int
+		err := s.db.QueryRowContext(ctx, database.ConvertPlaceholders(
+			"SELECT id FROM groups WHERE name = ?"), m.Group).Scan(&groupID)
+		if err == sql.ErrNoRows {
+			log.Printf("ldap: directory %s maps to unknown group %q", dir.Name, m.Group)
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("look up group %s: %w", m.Group, err)
+		}
+		mappings = append(mappings, models.SSOGroupMapping{Value: m.LDAPGroup, GroupID: groupID, Permission: m.Permission})
+	}
+	if err := syncMappedGroups(ctx, s.db, s.now(), mappings, user.Groups, table, userKey); err != nil {
+		return fmt.Errorf("sync ldap groups: %w", err)
+	}
+	return nil
+}
+
+// ldapDisplayName falls back to the login for missing names; both columns
+// are required.
+func ldapDisplayName(user *ldap.User) (string, string) {
+	first, last := user.FirstName, user.LastName
+	if first == "" && last == "" && user.DisplayName != "" && user.DisplayName != user.Username {
+		if i := strings.LastIndex(user.DisplayName, " "); i > 0 {
+			first, last = user.DisplayName[:i], user.DisplayName[i+1:]
+		} else {
+			first = user.DisplayName
+		}
+	}
+	if first == "" {
+		first = user.Username
+	}
+	if last == "" {
+		last = "-"
+	}
+	return first, last
+}
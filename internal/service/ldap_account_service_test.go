@@ -0,0 +1,79 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/ldap"
+)
+
+func TestLDAPAccountService_SyncAgent(t *testing.T) {
+	_, db := newSSOTestService(t)
+	ctx := context.Background()
+	dir := &ldap.Directory{
+		Name:     "corp",
+		Frontend: ldap.FrontendAgent,
+		GroupMappings: []ldap.GroupMapping{
+			{LDAPGroup: "Helpdesk", Group: "support", Permission: "rw"},
+			{LDAPGroup: "Helpdesk", Group: "missing", Permission: "rw"},
+		},
+	}
+	svc := NewLDAPAccountService(db, ldap.Directories{dir})
+	user := &ldap.User{Username: "jdoe", DisplayName: "Jane Doe", Groups: []string{"helpdesk"}}
+
+	_, _, err := svc.syncAgent(ctx, dir, user)
+	assert.ErrorIs(t, err, ErrLDAPUnknownAccount)
+
+	dir.AutoCreate = true
+	userID, state, err := svc.syncAgent(ctx, dir, user)
+	require.NoError(t, err)
+	assert.Equal(t, accountCreated, state)
+	assert.Equal(t, []string{"support:rw"}, agentGroups(t, db, userID))
+
+	var first, last string
+	require.NoError(t, db.QueryRow("SELECT first_name, last_name FROM users WHERE id = ?", userID).Scan(&first, &last))
+	assert.Equal(t, "Jane", first)
+	assert.Equal(t, "Doe", last)
+
+	// Leaving the directory group revokes the mapped permission.
+	user.FirstName, user.LastName, user.Groups = "Janet", "Doe", nil
+	again, state, err := svc.syncAgent(ctx, dir, user)
+	require.NoError(t, err)
+	assert.Equal(t, userID, again)
+	assert.Equal(t, accountUpdated, state)
+	assert.Empty(t, agentGroups(t, db, userID))
+
+	_, _, err = svc.syncAgent(ctx, dir, &ldap.User{Username: "disabled@example.com"})
+	assert.ErrorIs(t, err, ErrLDAPAccountDisabled)
+}
+
+func TestLDAPAccountService_SyncCustomer(t *testing.T) {
+	_, db := newSSOTestService(t)
+	ctx := context.Background()
+	dir := &ldap.Directory{Name: "customers", Frontend: ldap.FrontendCustomer, AutoCreate: true}
+	svc := NewLDAPAccountService(db, ldap.Directories{dir})
+
+	state, err := svc.syncCustomer(ctx, dir, &ldap.User{Username: "bob", Email: "bob@acme.example", FirstName: "Bob", LastName: "Buyer"})
+	require.NoError(t, err)
+	assert.Equal(t, accountCreated, state)
+
+	var email, customerID string
+	require.NoError(t, db.QueryRow("SELECT email, customer_id FROM customer_user WHERE login = 'bob'").Scan(&email, &customerID))
+	assert.Equal(t, "acme.example", customerID)
+
+	// The customer ID attribute wins once the directory provides it; an
+	// empty email keeps the stored one.
+	state, err = svc.syncCustomer(ctx, dir, &ldap.User{Username: "bob", FirstName: "Bob", LastName: "Buyer", CustomerID: "ACME"})
+	require.NoError(t, err)
+	assert.Equal(t, accountUpdated, state)
+	require.NoError(t, db.QueryRow("SELECT email, customer_id FROM customer_user WHERE login = 'bob'").Scan(&email, &customerID))
+	assert.Equal(t, "bob@acme.example", email)
+	assert.Equal(t, "ACME", customerID)
+
+	state, err = svc.syncCustomer(ctx, dir, &ldap.User{Username: "bob", FirstName: "Bob", LastName: "Buyer", CustomerID: "ACME"})
+	require.NoError(t, err)
+	assert.Equal(t, accountUnchanged, state)
+}
@@ -0,0 +1,249 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/goatkit/goatflow/internal/database"
+)
+
+// Out-of-office preference keys. The first seven are the keys OTRS uses, so
+// periods set in either system are shared; OutOfOfficeSubstitute is ours.
+const (
+	prefOutOfOffice           = "OutOfOffice"
+	prefOutOfOfficeStartYear  = "OutOfOfficeStartYear"
+	prefOutOfOfficeStartMonth = "OutOfOfficeStartMonth"
+	prefOutOfOfficeStartDay   = "OutOfOfficeStartDay"
+	prefOutOfOfficeEndYear    = "OutOfOfficeEndYear"
+	prefOutOfOfficeEndMonth   = "OutOfOfficeEndMonth"
+	prefOutOfOfficeEndDay     = "OutOfOfficeEndDay"
+	prefOutOfOfficeSubstitute = "OutOfOfficeSubstitute"
+)
+
+var outOfOfficeKeys = []string{
+	prefOutOfOffice,
+	prefOutOfOfficeStartYear, prefOutOfOfficeStartMonth, prefOutOfOfficeStartDay,
+	prefOutOfOfficeEndYear, prefOutOfOfficeEndMonth, prefOutOfOfficeEndDay,
+	prefOutOfOfficeSubstitute,
+}
+
+// maxSubstituteHops bounds how far ResolveAgent follows substitutes of
+// substitutes.
+const maxSubstituteHops = 5
+
+// OutOfOfficeDateFormat is the layout of OutOfOffice.Start and End.
+const OutOfOfficeDateFormat = "2006-01-02"
+
+// Errors returned by OutOfOfficeService.
+var (
+	ErrOutOfOfficeUserNotFound      = errors.New("user not found")
+	ErrOutOfOfficeInvalidPeriod     = errors.New("out-of-office period needs a start and end date (YYYY-MM-DD) with end not before start")
+	ErrOutOfOfficeInvalidSubstitute = errors.New("substitute must be a different, valid agent")
+	ErrAgentOutOfOffice             = errors.New("agent is out of office and no available substitute is set")
+)
+
+// OutOfOffice is an agent's out-of-office period. Start and End are whole
+// days and both are included in the period.
+type OutOfOffice struct {
+	UserID       int    `json:"user_id"`
+	Enabled      bool   `json:"enabled"`
+	Start        string `json:"start,omitempty"`
+	End          string `json:"end,omitempty"`
+	SubstituteID int    `json:"substitute_id,omitempty"`
+	// Active is true when the period is enabled and covers today.
+	Active bool `json:"active"`
+}
+
+// OutOfOfficeService stores agents' out-of-office periods in
+// user_preferences and resolves absent agents to their substitutes.
+type OutOfOfficeService struct {
+	db  *sql.DB
+	now func() time.Time
+}
+
+// NewOutOfOfficeService creates an out-of-office service.
+func NewOutOfOfficeService(db *sql.DB) *OutOfOfficeService {
+	return &OutOfOfficeService{db: db, now: time.Now}
+}
+
+// Get returns the out-of-office settings of a user. A user who never set
+// any is returned disabled.
+func (s *OutOfOfficeService) Get(ctx context.Context, userID int) (*OutOfOffice, error) {
+	args := []interface{}{userID}
+	placeholders := ""
+	for i, key := range outOfOfficeKeys {
+		if i > 0 {
+			placeholders += ", "
+		}
+		placeholders += "?"
+		args = append(args, key)
+	}
+	rows, err := s.db.QueryContext(ctx, database.ConvertPlaceholders(`
+		SELECT preferences_key, preferences_value
+		FROM user_preferences
+		WHERE user_id = ? AND preferences_key IN (`+placeholders+`)`), args...)
+	if err != nil {
+		return nil, fmt.Errorf("load out-of-office: %w", err)
+	}
+	defer rows.Close()
+
+	prefs := make(map[string]int, len(outOfOfficeKeys))
+	for rows.Next() {
+		var key string
+		var value []byte
+		if err := rows.Scan(&key, &value); err != nil {
+			return nil, fmt.Errorf("scan out-of-office: %w", err)
+		}
+		prefs[key], _ = strconv.Atoi(string(value))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate out-of-office: %w", err)
+	}
+
+	ooo := &OutOfOffice{
+		UserID:       userID,
+		Enabled:      prefs[prefOutOfOffice] == 1,
+		Start:        prefDate(prefs[prefOutOfOfficeStartYear], prefs[prefOutOfOfficeStartMonth], prefs[prefOutOfOfficeStartDay]),
+		End:          prefDate(prefs[prefOutOfOfficeEndYear], prefs[prefOutOfOfficeEndMonth], prefs[prefOutOfOfficeEndDay]),
+		SubstituteID: prefs[prefOutOfOfficeSubstitute],
+	}
+	ooo.Active = s.active(ooo)
+	return ooo, nil
+}
+
+func prefDate(year, month, day int) string {
+	if year == 0 || month == 0 || day == 0 {
+		return ""
+	}
+	return time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC).Format(OutOfOfficeDateFormat)
+}
+
+// active reports whether today, in local time, falls within the period.
+func (s *OutOfOfficeService) active(ooo *OutOfOffice) bool {
+	if !ooo.Enabled || ooo.Start == "" || ooo.End == "" {
+		return false
+	}
+	today := s.now().Format(OutOfOfficeDateFormat)
+	return ooo.Start <= today && today <= ooo.End
+}
+
+// Set validates and stores the out-of-office settings of ooo.UserID and
+// returns them as stored.
+func (s *OutOfOfficeService) Set(ctx context.Context, ooo OutOfOffice) (*OutOfOffice, error) {
+	if !s.validAgent(ctx, ooo.UserID) {
+		return nil, ErrOutOfOfficeUserNotFound
+	}
+
+	var start, end time.Time
+	if ooo.Start != "" || ooo.End != "" || ooo.Enabled {
+		var errStart, errEnd error
+		start, errStart = time.Parse(OutOfOfficeDateFormat, ooo.Start)
+		end, errEnd = time.Parse(OutOfOfficeDateFormat, ooo.End)
+		if errStart != nil || errEnd != nil || end.Before(start) {
+			return nil, ErrOutOfOfficeInvalidPeriod
+		}
+	}
+	if ooo.SubstituteID != 0 && (ooo.SubstituteID == ooo.UserID || !s.validAgent(ctx, ooo.SubstituteID)) {
+		return nil, ErrOutOfOfficeInvalidSubstitute
+	}
+
+	enabled := 0
+	if ooo.Enabled {
+		enabled = 1
+	}
+	values := map[string]int{
+		prefOutOfOffice:           enabled,
+		prefOutOfOfficeSubstitute: ooo.SubstituteID,
+	}
+	if !start.IsZero() {
+		values[prefOutOfOfficeStartYear] = start.Year()
+		values[prefOutOfOfficeStartMonth] = int(start.Month())
+		values[prefOutOfOfficeStartDay] = start.Day()
+		values[prefOutOfOfficeEndYear] = end.Year()
+		values[prefOutOfOfficeEndMonth] = int(end.Month())
+		values[prefOutOfOfficeEndDay] = end.Day()
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("begin out-of-office update: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck // no-op after commit
+
+	for _, key := range outOfOfficeKeys {
+		if _, err := tx.ExecContext(ctx, database.ConvertPlaceholders(`
+			DELETE FROM user_preferences WHERE user_id = ? AND preferences_key = ?`), ooo.UserID, key); err != nil {
+			return nil, fmt.Errorf("clear out-of-office: %w", err)
+		}
+		value, ok := values[key]
+		if !ok || (key == prefOutOfOfficeSubstitute && value == 0) {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, database.ConvertPlaceholders(`
+			INSERT INTO user_preferences (user_id, preferences_key, preferences_value)
+			VALUES (?, ?, ?)`), ooo.UserID, key, []byte(strconv.Itoa(value))); err != nil {
+			return nil, fmt.Errorf("store out-of-office: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("commit out-of-office: %w", err)
+	}
+	return s.Get(ctx, ooo.UserID)
+}
+
+func (s *OutOfOfficeService) validAgent(ctx context.Context, userID int) bool {
+	if userID <= 0 {
+		return false
+	}
+	var id int
+	err := s.db.QueryRowContext(ctx, database.ConvertPlaceholders(
+		`SELECT id FROM users WHERE id = ? AND valid_id = 1`), userID).Scan(&id)
+	return err == nil
+}
+
+// ResolveAgent returns the agent that should receive work meant for userID:
+// userID itself when present, otherwise the first available agent along its
+// substitute chain. It returns ErrAgentOutOfOffice when there is none.
+func (s *OutOfOfficeService) ResolveAgent(ctx context.Context, userID int) (int, error) {
+	seen := map[int]bool{}
+	current := userID
+	for hop := 0; hop <= maxSubstituteHops; hop++ {
+		ooo, err := s.Get(ctx, current)
+		if err != nil {
+			return 0, err
+		}
+		if !ooo.Active {
+			return current, nil
+		}
+		seen[current] = true
+		next := ooo.SubstituteID
+		if next == 0 || seen[next] || !s.validAgent(ctx, next) {
+			break
+		}
+		current = next
+	}
+	return 0, fmt.Errorf("%w (user %d)", ErrAgentOutOfOffice, userID)
+}
+
+// SubstituteRecipients maps notification recipients to their substitutes
+// and drops duplicates. Agents without an available substitute are kept,
+// so a notification is never lost.
+func (s *OutOfOfficeService) SubstituteRecipients(ctx context.Context, userIDs []int) []int {
+	out := make([]int, 0, len(userIDs))
+	seen := make(map[int]bool, len(userIDs))
+	for _, id := range userIDs {
+		resolved, err := s.ResolveAgent(ctx, id)
+		if err != nil {
+			resolved = id
+		}
+		if !seen[resolved] {
+			seen[resolved] = true
+			out = append(out, resolved)
+		}
+	}
+	return out
+}
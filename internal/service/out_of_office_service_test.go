@@ -0,0 +1,128 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/testutil"
+)
+
+func newOutOfOfficeTestService(t *testing.T) (*OutOfOfficeService, *sql.DB) {
+	t.Helper()
+	db := testutil.MigratedDB(t)
+	for _, u := range []struct{ id, validID int }{{1, 1}, {2, 1}, {3, 1}, {4, 2}} {
+		_, err := db.Exec(`INSERT INTO users (id, login, pw, first_name, last_name, valid_id,
+			create_time, create_by, change_time, change_by)
+			VALUES (?, ?, 'x', 'Agent', ?, ?, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+			u.id, fmt.Sprintf("agent%d", u.id), fmt.Sprintf("%d", u.id), u.validID)
+		require.NoError(t, err)
+	}
+
+	svc := NewOutOfOfficeService(db)
+	svc.now = func() time.Time { return time.Date(2025, 3, 10, 12, 0, 0, 0, time.Local) }
+	return svc, db
+}
+
+func TestOutOfOfficeService_SetAndGet(t *testing.T) {
+	svc, db := newOutOfOfficeTestService(t)
+	ctx := context.Background()
+
+	ooo, err := svc.Get(ctx, 1)
+	require.NoError(t, err)
+	assert.False(t, ooo.Enabled)
+	assert.False(t, ooo.Active)
+
+	ooo, err = svc.Set(ctx, OutOfOffice{UserID: 1, Enabled: true, Start: "2025-03-10", End: "2025-03-14", SubstituteID: 2})
+	require.NoError(t, err)
+	assert.True(t, ooo.Active)
+	assert.Equal(t, "2025-03-10", ooo.Start)
+	assert.Equal(t, "2025-03-14", ooo.End)
+	assert.Equal(t, 2, ooo.SubstituteID)
+
+	// Dates are stored in the OTRS preference keys.
+	var month []byte
+	require.NoError(t, db.QueryRow(`SELECT preferences_value FROM user_preferences
+		WHERE user_id = 1 AND preferences_key = 'OutOfOfficeEndMonth'`).Scan(&month))
+	assert.Equal(t, "3", string(month))
+
+	// Disabling keeps the period but clears the substitute.
+	ooo, err = svc.Set(ctx, OutOfOffice{UserID: 1, Start: "2025-03-10", End: "2025-03-14"})
+	require.NoError(t, err)
+	assert.False(t, ooo.Active)
+	assert.Equal(t, "2025-03-10", ooo.Start)
+	assert.Zero(t, ooo.SubstituteID)
+}
+
+func TestOutOfOfficeService_Validation(t *testing.T) {
+	svc, _ := newOutOfOfficeTestService(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		name string
+		ooo  OutOfOffice
+		want error
+	}{
+		{"unknown user", OutOfOffice{UserID: 99}, ErrOutOfOfficeUserNotFound},
+		{"enabled without dates", OutOfOffice{UserID: 1, Enabled: true}, ErrOutOfOfficeInvalidPeriod},
+		{"end before start", OutOfOffice{UserID: 1, Enabled: true, Start: "2025-03-10", End: "2025-03-09"}, ErrOutOfOfficeInvalidPeriod},
+		{"bad date", OutOfOffice{UserID: 1, Start: "10/03/2025", End: "2025-03-11"}, ErrOutOfOfficeInvalidPeriod},
+		{"self substitute", OutOfOffice{UserID: 1, SubstituteID: 1}, ErrOutOfOfficeInvalidSubstitute},
+		{"invalid substitute", OutOfOffice{UserID: 1, SubstituteID: 4}, ErrOutOfOfficeInvalidSubstitute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := svc.Set(ctx, tt.ooo)
+			assert.ErrorIs(t, err, tt.want)
+		})
+	}
+}
+
+func TestOutOfOfficeService_ResolveAgent(t *testing.T) {
+	svc, _ := newOutOfOfficeTestService(t)
+	ctx := context.Background()
+
+	id, err := svc.ResolveAgent(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 1, id)
+
+	// A period in the future does not apply yet.
+	_, err = svc.Set(ctx, OutOfOffice{UserID: 1, Enabled: true, Start: "2025-04-01", End: "2025-04-02", SubstituteID: 2})
+	require.NoError(t, err)
+	id, err = svc.ResolveAgent(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 1, id)
+
+	// Substitutes of absent substitutes are followed.
+	_, err = svc.Set(ctx, OutOfOffice{UserID: 1, Enabled: true, Start: "2025-03-01", End: "2025-03-31", SubstituteID: 2})
+	require.NoError(t, err)
+	_, err = svc.Set(ctx, OutOfOffice{UserID: 2, Enabled: true, Start: "2025-03-10", End: "2025-03-10", SubstituteID: 3})
+	require.NoError(t, err)
+	id, err = svc.ResolveAgent(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 3, id)
+
+	// A cycle of absent agents has nobody to resolve to.
+	_, err = svc.Set(ctx, OutOfOffice{UserID: 3, Enabled: true, Start: "2025-03-10", End: "2025-03-10", SubstituteID: 1})
+	require.NoError(t, err)
+	_, err = svc.ResolveAgent(ctx, 1)
+	assert.ErrorIs(t, err, ErrAgentOutOfOffice)
+}
+
+func TestOutOfOfficeService_SubstituteRecipients(t *testing.T) {
+	svc, _ := newOutOfOfficeTestService(t)
+	ctx := context.Background()
+
+	_, err := svc.Set(ctx, OutOfOffice{UserID: 1, Enabled: true, Start: "2025-03-01", End: "2025-03-31", SubstituteID: 2})
+	require.NoError(t, err)
+	_, err = svc.Set(ctx, OutOfOffice{UserID: 3, Enabled: true, Start: "2025-03-01", End: "2025-03-31"})
+	require.NoError(t, err)
+
+	// 1 goes to 2 (already a recipient); 3 has no substitute and is kept.
+	assert.Equal(t, []int{2, 3}, svc.SubstituteRecipients(ctx, []int{1, 2, 3}))
+}
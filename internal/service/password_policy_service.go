@@ -0,0 +1,391 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/goatkit/goatflow/internal/auth"
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/sysconfig"
+)
+
+// PasswordAccountType selects the accounts a password policy applies to.
+type PasswordAccountType string
+
+// Account types with a password policy of their own.
+const (
+	PasswordAccountAgent    PasswordAccountType = "agent"
+	PasswordAccountCustomer PasswordAccountType = "customer"
+)
+
+// Errors returned by PasswordPolicyService.
+var (
+	ErrPasswordAccountType     = errors.New("account type must be agent or customer")
+	ErrPasswordAccountNotFound = errors.New("account not found")
+	ErrPasswordPolicyInvalid   = errors.New("invalid password policy")
+)
+
+// loginFailedPreference is the preference counting failed logins since the
+// last successful one, named as in OTRS.
+const loginFailedPreference = "UserLoginFailed"
+
+// changeRequiredPreference marks an account that signed in with an expired
+// password and may do nothing else until it changes it.
+const changeRequiredPreference = "UserPasswordChangeRequired"
+
+// validTemporarilyInvalid is the valid_id a locked account is set to.
+const validTemporarilyInvalid = 3
+
+// PasswordPolicyService enforces the agent and customer password policies
+// beyond the composition rules of sysconfig.PasswordPolicy: it refuses
+// reused and breached passwords, tells when a password has expired and
+// locks accounts after too many failed logins, as OTRS does, by setting
+// them invalid-temporarily.
+type PasswordPolicyService struct {
+	db     *sql.DB
+	breach BreachChecker
+	now    func() time.Time
+}
+
+// NewPasswordPolicyService creates a password policy service that checks
+// breaches against the public HaveIBeenPwned API.
+func NewPasswordPolicyService(db *sql.DB) *PasswordPolicyService {
+	return &PasswordPolicyService{db: db, breach: NewPwnedPasswords(), now: time.Now}
+}
+
+// WithBreachChecker replaces the breach checker, for tests and for
+// installations that mirror the breach corpus.
+func (s *PasswordPolicyService) WithBreachChecker(b BreachChecker) *PasswordPolicyService {
+	s.breach = b
+	return s
+}
+
+// Policy returns the password policy of an account type.
+func (s *PasswordPolicyService) Policy(kind PasswordAccountType) (sysconfig.PasswordPolicy, error) {
+	switch kind {
+	case PasswordAccountAgent:
+		return sysconfig.LoadAgentPasswordPolicy(s.db)
+	case PasswordAccountCustomer:
+		return sysconfig.LoadCustomerPasswordPolicy(s.db)
+	}
+	return sysconfig.PasswordPolicy{}, ErrPasswordAccountType
+}
+
+// SavePolicy validates and stores the password policy of an account type.
+func (s *PasswordPolicyService) SavePolicy(kind PasswordAccountType, policy sysconfig.PasswordPolicy, userID int) (sysconfig.PasswordPolicy, error) {
+	for name, n := range map[string]int{
+		"password_min_size":               policy.PasswordMinSize,
+		"password_max_login_failed":       policy.PasswordMaxLoginFailed,
+		"password_history":                policy.PasswordHistory,
+		"password_max_valid_time_in_days": policy.PasswordMaxValidTimeInDays,
+	} {
+		if n < 0 {
+			return sysconfig.PasswordPolicy{}, fmt.Errorf("%w: %s cannot be negative", ErrPasswordPolicyInvalid, name)
+		}
+	}
+	if _, err := regexp.Compile(policy.PasswordRegExp); err != nil {
+		return sysconfig.PasswordPolicy{}, fmt.Errorf("%w: password_reg_exp: %v", ErrPasswordPolicyInvalid, err)
+	}
+
+	var err error
+	switch kind {
+	case PasswordAccountAgent:
+		err = sysconfig.SaveAgentPasswordPolicy(s.db, policy, userID)
+	case PasswordAccountCustomer:
+		err = sysconfig.SaveCustomerPasswordPolicy(s.db, policy, userID)
+	default:
+		return sysconfig.PasswordPolicy{}, ErrPasswordAccountType
+	}
+	if err != nil {
+		return sysconfig.PasswordPolicy{}, err
+	}
+	return s.Policy(kind)
+}
+
+// CheckPassword checks a new password for the account with the given login
+// against the policy: its composition rules, the account's recent passwords
+// and, when enabled, the breach corpus. It returns nil if the password is
+// acceptable. A failed breach lookup lets the password through, so an
+// unreachable API does not block password changes.
+func (s *PasswordPolicyService) CheckPassword(ctx context.Context, kind PasswordAccountType, login, password string) (*sysconfig.PasswordValidationError, error) {
+	policy, err := s.Policy(kind)
+	if err != nil {
+		return nil, err
+	}
+	if verr := policy.ValidatePassword(password); verr != nil {
+		return verr, nil
+	}
+
+	if policy.PasswordHistory > 0 {
+		hashes, err := s.recentHashes(ctx, kind, login, policy.PasswordHistory)
+		if err != nil {
+			return nil, err
+		}
+		hasher := auth.NewPasswordHasher()
+		for _, hash := range hashes {
+			if hasher.VerifyPassword(password, hash) {
+				return &sysconfig.PasswordValidationError{Code: "history", Message: "password_policy.history"}, nil
+			}
+		}
+	}
+
+	if policy.PasswordBreachCheck && s.breach != nil {
+		breached, err := s.breach.Breached(ctx, password)
+		if err != nil {
+			log.Printf("password breach check for %s %s skipped: %v", kind, login, err)
+		} else if breached {
+			return &sysconfig.PasswordValidationError{Code: "breached", Message: "password_policy.breached"}, nil
+		}
+	}
+	return nil, nil
+}
+
+// RecordChange stores the hash of an account's new password, which starts
+// its validity period, and drops the history the policy no longer needs.
+func (s *PasswordPolicyService) RecordChange(ctx context.Context, kind PasswordAccountType, login, hash string) error {
+	policy, err := s.Policy(kind)
+	if err != nil {
+		return err
+	}
+	if _, err := s.db.ExecContext(ctx, database.ConvertPlaceholders(`
+		INSERT INTO password_history (user_type, login, pw, create_time)
+		VALUES (?, ?, ?, ?)
+	`), string(kind), login, hash, s.now()); err != nil {
+		return fmt.Errorf("record password change: %w", err)
+	}
+
+	// The newest entry is always kept: it dates the current password.
+	keep := policy.PasswordHistory
+	if keep < 1 {
+		keep = 1
+	}
+	rows, err := s.db.QueryContext(ctx, database.ConvertPlaceholders(`
+		SELECT id FROM password_history
+		WHERE user_type = ? AND login = ?
+		ORDER BY create_time DESC, id DESC
+	`), string(kind), login)
+	if err != nil {
+		return fmt.Errorf("prune password history: %w", err)
+	}
+	var stale []int64
+	for n := 0; rows.Next(); n++ {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return fmt.Errorf("prune password history: %w", err)
+		}
+		if n >= keep {
+			stale = append(stale, id)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("prune password history: %w", err)
+	}
+	for _, id := range stale {
+		if _, err := s.db.ExecContext(ctx, database.ConvertPlaceholders(`DELETE FROM password_history WHERE id = ?`), id); err != nil {
+			return fmt.Errorf("prune password history: %w", err)
+		}
+	}
+
+	// A new password lifts the change requirement. Accounts still being
+	// registered have nothing to lift.
+	acct, err := s.account(ctx, kind, login)
+	if errors.Is(err, ErrPasswordAccountNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return s.deletePreference(kind, acct, changeRequiredPreference)
+}
+
+// RequireChange marks an account as having to change its password before
+// it can do anything else. RecordChange lifts the requirement.
+func (s *PasswordPolicyService) RequireChange(ctx context.Context, kind PasswordAccountType, login string) error {
+	acct, err := s.account(ctx, kind, login)
+	if err != nil {
+		return err
+	}
+	return s.setPreference(kind, acct, changeRequiredPreference, "1")
+}
+
+// ChangeRequired reports whether an account, given by ID as sessions carry
+// it, has to change its password before it can do anything else.
+func (s *PasswordPolicyService) ChangeRequired(ctx context.Context, kind PasswordAccountType, userID int) (bool, error) {
+	acct, err := s.accountByID(ctx, kind, userID)
+	if err != nil {
+		return false, err
+	}
+	value, err := s.getPreference(kind, acct, changeRequiredPreference)
+	return value == "1", err
+}
+
+// Expired reports whether an account's password is older than the policy
+// allows. Passwords changed before history was recorded are dated by the
+// account's creation.
+func (s *PasswordPolicyService) Expired(ctx context.Context, kind PasswordAccountType, login string) (bool, error) {
+	policy, err := s.Policy(kind)
+	if err != nil {
+		return false, err
+	}
+	if policy.PasswordMaxValidTimeInDays == 0 {
+		return false, nil
+	}
+
+	var changed time.Time
+	err = s.db.QueryRowContext(ctx, database.ConvertPlaceholders(`
+		SELECT create_time FROM password_history
+		WHERE user_type = ? AND login = ?
+		ORDER BY create_time DESC, id DESC
+		LIMIT 1
+	`), string(kind), login).Scan(&changed)
+	if errors.Is(err, sql.ErrNoRows) {
+		var acct passwordAccount
+		acct, err = s.account(ctx, kind, login)
+		changed = acct.created
+	}
+	if err != nil {
+		return false, err
+	}
+	maxAge := time.Duration(policy.PasswordMaxValidTimeInDays) * 24 * time.Hour
+	return s.now().Sub(changed) > maxAge, nil
+}
+
+// RecordLoginFailure counts a failed login for the account and locks it
+// once the policy's maximum is reached, returning whether it did. Logins of
+// accounts that do not exist locally return ErrPasswordAccountNotFound.
+func (s *PasswordPolicyService) RecordLoginFailure(ctx context.Context, kind PasswordAccountType, login string) (bool, error) {
+	policy, err := s.Policy(kind)
+	if err != nil {
+		return false, err
+	}
+	acct, err := s.account(ctx, kind, login)
+	if err != nil {
+		return false, err
+	}
+	if policy.PasswordMaxLoginFailed == 0 || acct.validID != 1 {
+		return false, nil
+	}
+
+	value, err := s.getPreference(kind, acct, loginFailedPreference)
+	if err != nil {
+		return false, err
+	}
+	failed, _ := strconv.Atoi(value)
+	failed++
+	if failed < policy.PasswordMaxLoginFailed {
+		return false, s.setPreference(kind, acct, loginFailedPreference, strconv.Itoa(failed))
+	}
+
+	// The count starts over once an admin makes the account valid again.
+	query := `UPDATE users SET valid_id = ?, change_time = CURRENT_TIMESTAMP WHERE id = ?`
+	if kind == PasswordAccountCustomer {
+		query = `UPDATE customer_user SET valid_id = ?, change_time = CURRENT_TIMESTAMP WHERE id = ?`
+	}
+	if _, err := s.db.ExecContext(ctx, database.ConvertPlaceholders(query), validTemporarilyInvalid, acct.id); err != nil {
+		return false, fmt.Errorf("lock account: %w", err)
+	}
+	log.Printf("%s %s locked after %d failed logins", kind, login, failed)
+	return true, s.deletePreference(kind, acct, loginFailedPreference)
+}
+
+// RecordLoginSuccess resets the account's failed login count.
+func (s *PasswordPolicyService) RecordLoginSuccess(ctx context.Context, kind PasswordAccountType, login string) error {
+	acct, err := s.account(ctx, kind, login)
+	if err != nil {
+		return err
+	}
+	return s.deletePreference(kind, acct, loginFailedPreference)
+}
+
+// recentHashes returns the hashes of the account's n most recent passwords.
+func (s *PasswordPolicyService) recentHashes(ctx context.Context, kind PasswordAccountType, login string, n int) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, database.ConvertPlaceholders(`
+		SELECT pw FROM password_history
+		WHERE user_type = ? AND login = ?
+		ORDER BY create_time DESC, id DESC
+	`), string(kind), login)
+	if err != nil {
+		return nil, fmt.Errorf("load password history: %w", err)
+	}
+	defer rows.Close()
+	var hashes []string
+	for rows.Next() && len(hashes) < n {
+		var hash string
+		if err := rows.Scan(&hash); err != nil {
+			return nil, fmt.Errorf("load password history: %w", err)
+		}
+		hashes = append(hashes, hash)
+	}
+	return hashes, rows.Err()
+}
+
+// passwordAccount is the part of an agent or customer row the policy needs.
+type passwordAccount struct {
+	id      int
+	login   string
+	validID int
+	created time.Time
+}
+
+func (s *PasswordPolicyService) account(ctx context.Context, kind PasswordAccountType, login string) (passwordAccount, error) {
+	return s.findAccount(ctx, kind, "login", login)
+}
+
+func (s *PasswordPolicyService) accountByID(ctx context.Context, kind PasswordAccountType, id int) (passwordAccount, error) {
+	return s.findAccount(ctx, kind, "id", id)
+}
+
+// findAccount loads an account by its id or login column.
+func (s *PasswordPolicyService) findAccount(ctx context.Context, kind PasswordAccountType, column string, value interface{}) (passwordAccount, error) {
+	var table string
+	switch kind {
+	case PasswordAccountAgent:
+		table = "users"
+	case PasswordAccountCustomer:
+		table = "customer_user"
+	default:
+		return passwordAccount{}, ErrPasswordAccountType
+	}
+	var acct passwordAccount
+	err := s.db.QueryRowContext(ctx, database.ConvertPlaceholders(
+		"SELECT id, login, valid_id, create_time FROM "+table+" WHERE "+column+" = ?"), value).
+		Scan(&acct.id, &acct.login, &acct.validID, &acct.created)
+	if errors.Is(err, sql.ErrNoRows) {
+		return passwordAccount{}, ErrPasswordAccountNotFound
+	}
+	if err != nil {
+		return passwordAccount{}, fmt.Errorf("load %s: %w", kind, err)
+	}
+	return acct, nil
+}
+
+// Agent preferences are keyed by user ID, customer preferences by login.
+
+func (s *PasswordPolicyService) getPreference(kind PasswordAccountType, acct passwordAccount, key string) (string, error) {
+	if kind == PasswordAccountCustomer {
+		return NewCustomerPreferencesService(s.db).GetPreference(acct.login, key)
+	}
+	return NewUserPreferencesService(s.db).GetPreference(acct.id, key)
+}
+
+func (s *PasswordPolicyService) setPreference(kind PasswordAccountType, acct passwordAccount, key, value string) error {
+	if kind == PasswordAccountCustomer {
+		return NewCustomerPreferencesService(s.db).SetPreference(acct.login, key, value)
+	}
+	return NewUserPreferencesService(s.db).SetPreference(acct.id, key, value)
+}
+
+func (s *PasswordPolicyService) deletePreference(kind PasswordAccountType, acct passwordAccount, key string) error {
+	if kind == PasswordAccountCustomer {
+		return NewCustomerPreferencesService(s.db).DeletePreference(acct.login, key)
+	}
+	return NewUserPreferencesService(s.db).DeletePreference(acct.id, key)
+}
@@ -0,0 +1,271 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/auth"
+	"github.com/goatkit/goatflow/internal/sysconfig"
+	"github.com/goatkit/goatflow/internal/testutil"
+)
+
+type fakeBreachChecker struct {
+	breached map[string]bool
+	err      error
+}
+
+func (f *fakeBreachChecker) Breached(_ context.Context, password string) (bool, error) {
+	return f.breached[password], f.err
+}
+
+func newPasswordPolicyTestService(t *testing.T) (*PasswordPolicyService, *sql.DB) {
+	t.Helper()
+	// Saving the policy upserts sysconfig rows in the driver's dialect.
+	t.Setenv("TEST_DB_DRIVER", "sqlite")
+	db := testutil.MigratedDB(t)
+	for _, stmt := range []string{
+		`INSERT INTO users (id, login, pw, first_name, last_name, valid_id, create_time, create_by, change_time, change_by)
+			VALUES (1, 'agent', 'x', 'Ada', 'Agent', 1, '2026-01-01 00:00:00', 1, '2026-01-01 00:00:00', 1)`,
+		`INSERT INTO customer_user (id, login, email, customer_id, pw, first_name, last_name, valid_id,
+			create_time, create_by, change_time, change_by)
+			VALUES (5, 'cust', 'cust@example.com', 'ACME', 'x', 'Cust', 'Omer', 1, '2026-01-01 00:00:00', 1, '2026-01-01 00:00:00', 1)`,
+	} {
+		_, err := db.Exec(stmt)
+		require.NoError(t, err, stmt)
+	}
+	svc := NewPasswordPolicyService(db).WithBreachChecker(&fakeBreachChecker{})
+	return svc, db
+}
+
+func TestPasswordPolicyService_SavePolicy(t *testing.T) {
+	svc, _ := newPasswordPolicyTestService(t)
+
+	policy, err := svc.Policy(PasswordAccountCustomer)
+	require.NoError(t, err)
+	assert.Equal(t, sysconfig.DefaultCustomerPasswordPolicy(), policy)
+
+	saved, err := svc.SavePolicy(PasswordAccountCustomer, sysconfig.PasswordPolicy{
+		PasswordMinSize:            10,
+		PasswordNeedDigit:          true,
+		PasswordMaxLoginFailed:     5,
+		PasswordHistory:            3,
+		PasswordMaxValidTimeInDays: 90,
+		PasswordBreachCheck:        true,
+	}, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 10, saved.PasswordMinSize)
+	assert.Equal(t, 5, saved.PasswordMaxLoginFailed)
+	assert.Equal(t, 3, saved.PasswordHistory)
+	assert.Equal(t, 90, saved.PasswordMaxValidTimeInDays)
+	assert.True(t, saved.PasswordBreachCheck)
+
+	// Saving again updates the overrides; the agent policy is separate.
+	saved, err = svc.SavePolicy(PasswordAccountCustomer, sysconfig.PasswordPolicy{PasswordMinSize: 12}, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 12, saved.PasswordMinSize)
+	assert.False(t, saved.PasswordBreachCheck)
+	agent, err := svc.Policy(PasswordAccountAgent)
+	require.NoError(t, err)
+	assert.Equal(t, 0, agent.PasswordMinSize)
+
+	_, err = svc.SavePolicy(PasswordAccountAgent, sysconfig.PasswordPolicy{PasswordHistory: -1}, 1)
+	assert.ErrorIs(t, err, ErrPasswordPolicyInvalid)
+	_, err = svc.SavePolicy(PasswordAccountAgent, sysconfig.PasswordPolicy{PasswordRegExp: "[a-"}, 1)
+	assert.ErrorIs(t, err, ErrPasswordPolicyInvalid)
+	_, err = svc.SavePolicy("robot", sysconfig.PasswordPolicy{}, 1)
+	assert.ErrorIs(t, err, ErrPasswordAccountType)
+}
+
+func TestPasswordPolicyService_HistoryAndBreaches(t *testing.T) {
+	svc, db := newPasswordPolicyTestService(t)
+	ctx := context.Background()
+	breaches := &fakeBreachChecker{breached: map[string]bool{"password123": true}}
+	svc.WithBreachChecker(breaches)
+	_, err := svc.SavePolicy(PasswordAccountAgent, sysconfig.PasswordPolicy{PasswordHistory: 2, PasswordBreachCheck: true}, 1)
+	require.NoError(t, err)
+
+	hasher := auth.NewPasswordHasher()
+	for _, pw := range []string{"first-secret", "second-secret", "third-secret"} {
+		hash, err := hasher.HashPassword(pw)
+		require.NoError(t, err)
+		require.NoError(t, svc.RecordChange(ctx, PasswordAccountAgent, "agent", hash))
+	}
+	var n int
+	require.NoError(t, db.QueryRow(`SELECT COUNT(*) FROM password_history WHERE login = 'agent'`).Scan(&n))
+	assert.Equal(t, 2, n, "history is trimmed to the policy's length")
+
+	verr, err := svc.CheckPassword(ctx, PasswordAccountAgent, "agent", "second-secret")
+	require.NoError(t, err)
+	require.NotNil(t, verr)
+	assert.Equal(t, "history", verr.Code)
+
+	verr, err = svc.CheckPassword(ctx, PasswordAccountAgent, "agent", "first-secret")
+	require.NoError(t, err)
+	assert.Nil(t, verr, "passwords older than the history can be reused")
+
+	// Other accounts' history does not count.
+	verr, err = svc.CheckPassword(ctx, PasswordAccountCustomer, "agent", "second-secret")
+	require.NoError(t, err)
+	assert.Nil(t, verr)
+
+	verr, err = svc.CheckPassword(ctx, PasswordAccountAgent, "agent", "password123")
+	require.NoError(t, err)
+	require.NotNil(t, verr)
+	assert.Equal(t, "breached", verr.Code)
+
+	// An unavailable breach API lets the password through.
+	breaches.err = errors.New("timeout")
+	verr, err = svc.CheckPassword(ctx, PasswordAccountAgent, "agent", "password123")
+	require.NoError(t, err)
+	assert.Nil(t, verr)
+}
+
+func TestPasswordPolicyService_Expired(t *testing.T) {
+	svc, _ := newPasswordPolicyTestService(t)
+	ctx := context.Background()
+	now := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	svc.now = func() time.Time { return now }
+
+	expired, err := svc.Expired(ctx, PasswordAccountCustomer, "cust")
+	require.NoError(t, err)
+	assert.False(t, expired, "passwords never expire by default")
+
+	_, err = svc.SavePolicy(PasswordAccountCustomer, sysconfig.PasswordPolicy{PasswordMaxValidTimeInDays: 30}, 1)
+	require.NoError(t, err)
+	expired, err = svc.Expired(ctx, PasswordAccountCustomer, "cust")
+	require.NoError(t, err)
+	assert.True(t, expired, "without history the account's creation dates the password")
+
+	require.NoError(t, svc.RecordChange(ctx, PasswordAccountCustomer, "cust", "hash"))
+	now = now.Add(29 * 24 * time.Hour)
+	expired, err = svc.Expired(ctx, PasswordAccountCustomer, "cust")
+	require.NoError(t, err)
+	assert.False(t, expired)
+	now = now.Add(2 * 24 * time.Hour)
+	expired, err = svc.Expired(ctx, PasswordAccountCustomer, "cust")
+	require.NoError(t, err)
+	assert.True(t, expired)
+
+	_, err = svc.Expired(ctx, PasswordAccountCustomer, "nobody")
+	assert.ErrorIs(t, err, ErrPasswordAccountNotFound)
+}
+
+func TestPasswordPolicyService_ChangeRequired(t *testing.T) {
+	svc, _ := newPasswordPolicyTestService(t)
+	ctx := context.Background()
+
+	for _, tc := range []struct {
+		kind  PasswordAccountType
+		login string
+		id    int
+	}{{PasswordAccountAgent, "agent", 1}, {PasswordAccountCustomer, "cust", 5}} {
+		required, err := svc.ChangeRequired(ctx, tc.kind, tc.id)
+		require.NoError(t, err)
+		assert.False(t, required)
+
+		require.NoError(t, svc.RequireChange(ctx, tc.kind, tc.login))
+		required, err = svc.ChangeRequired(ctx, tc.kind, tc.id)
+		require.NoError(t, err)
+		assert.True(t, required, "%s should have to change its password", tc.kind)
+
+		require.NoError(t, svc.RecordChange(ctx, tc.kind, tc.login, "hash"))
+		required, err = svc.ChangeRequired(ctx, tc.kind, tc.id)
+		require.NoError(t, err)
+		assert.False(t, required, "a new password lifts the requirement")
+	}
+
+	_, err := svc.ChangeRequired(ctx, PasswordAccountAgent, 99)
+	assert.ErrorIs(t, err, ErrPasswordAccountNotFound)
+}
+
+func TestPasswordPolicyService_Lockout(t *testing.T) {
+	svc, db := newPasswordPolicyTestService(t)
+	ctx := context.Background()
+
+	// Disabled by default: failures are not counted.
+	locked, err := svc.RecordLoginFailure(ctx, PasswordAccountAgent, "agent")
+	require.NoError(t, err)
+	assert.False(t, locked)
+
+	for _, kind := range []PasswordAccountType{PasswordAccountAgent, PasswordAccountCustomer} {
+		_, err = svc.SavePolicy(kind, sysconfig.PasswordPolicy{PasswordMaxLoginFailed: 3}, 1)
+		require.NoError(t, err)
+	}
+
+	for i := 0; i < 2; i++ {
+		locked, err = svc.RecordLoginFailure(ctx, PasswordAccountAgent, "agent")
+		require.NoError(t, err)
+		assert.False(t, locked)
+	}
+	// A successful login starts the count over.
+	require.NoError(t, svc.RecordLoginSuccess(ctx, PasswordAccountAgent, "agent"))
+	for i := 0; i < 2; i++ {
+		locked, err = svc.RecordLoginFailure(ctx, PasswordAccountAgent, "agent")
+		require.NoError(t, err)
+		assert.False(t, locked)
+	}
+	locked, err = svc.RecordLoginFailure(ctx, PasswordAccountAgent, "agent")
+	require.NoError(t, err)
+	assert.True(t, locked)
+
+	var validID int
+	require.NoError(t, db.QueryRow(`SELECT valid_id FROM users WHERE id = 1`).Scan(&validID))
+	assert.Equal(t, 3, validID)
+	var prefs int
+	require.NoError(t, db.QueryRow(`SELECT COUNT(*) FROM user_preferences`).Scan(&prefs))
+	assert.Zero(t, prefs, "the count starts over when the account is made valid again")
+
+	// Locked accounts are not counted further.
+	locked, err = svc.RecordLoginFailure(ctx, PasswordAccountAgent, "agent")
+	require.NoError(t, err)
+	assert.False(t, locked)
+
+	// Customers are counted by login.
+	for i := 0; i < 2; i++ {
+		_, err = svc.RecordLoginFailure(ctx, PasswordAccountCustomer, "cust")
+		require.NoError(t, err)
+	}
+	var value string
+	require.NoError(t, db.QueryRow(`SELECT preferences_value FROM customer_preferences WHERE user_id = 'cust'`).Scan(&value))
+	assert.Equal(t, "2", value)
+	require.NoError(t, db.QueryRow(`SELECT valid_id FROM customer_user WHERE id = 5`).Scan(&validID))
+	assert.Equal(t, 1, validID)
+
+	_, err = svc.RecordLoginFailure(ctx, PasswordAccountCustomer, "nobody")
+	assert.ErrorIs(t, err, ErrPasswordAccountNotFound)
+}
+
+func TestPwnedPasswords_Breached(t *testing.T) {
+	// SHA-1 of "password" is 5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8.
+	var gotPath, gotPadding string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath, gotPadding = r.URL.Path, r.Header.Get("Add-Padding")
+		w.Write([]byte("0018A45C4D1DEF81644B54AB7F969B88D65:0\r\n1E4C9B93F3F0682250B6CF8331B7EE68FD8:3861493\r\n011053FD0102E94D6AE2F8B83D76FAF94F6:0\r\n"))
+	}))
+	defer srv.Close()
+
+	p := &PwnedPasswords{BaseURL: srv.URL + "/range/", Client: srv.Client()}
+	breached, err := p.Breached(context.Background(), "password")
+	require.NoError(t, err)
+	assert.True(t, breached)
+	assert.Equal(t, "/range/5BAA6", gotPath, "only the hash prefix is sent")
+	assert.Equal(t, "true", gotPadding)
+
+	breached, err = p.Breached(context.Background(), "correct horse battery staple goat")
+	require.NoError(t, err)
+	assert.False(t, breached)
+
+	srv.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	_, err = p.Breached(context.Background(), "password")
+	assert.Error(t, err)
+}
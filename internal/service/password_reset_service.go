@@ -0,0 +1,371 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/goatkit/goatflow/internal/auth"
+	"github.com/goatkit/goatflow/internal/config"
+	"github.com/goatkit/goatflow/internal/mailqueue"
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/repository"
+	"github.com/goatkit/goatflow/internal/sysconfig"
+)
+
+// Errors returned by PasswordResetService.
+var (
+	ErrPasswordResetDisabled        = errors.New("password reset is disabled")
+	ErrPasswordResetInvalid         = errors.New("invalid password reset")
+	ErrPasswordResetLocked          = errors.New("too many password reset requests")
+	ErrPasswordResetLinkInvalid     = errors.New("password reset link is invalid")
+	ErrPasswordResetLinkExpired     = errors.New("password reset link has expired")
+	ErrPasswordResetSettings        = errors.New("invalid password reset settings")
+	ErrPasswordResetLockoutNotFound = errors.New("password reset lockout not found")
+)
+
+// PasswordResetLockedError is returned while an account is locked out of
+// password resets. It matches ErrPasswordResetLocked.
+type PasswordResetLockedError struct {
+	Until time.Time
+}
+
+func (e *PasswordResetLockedError) Error() string {
+	return ErrPasswordResetLocked.Error() + ", locked until " + e.Until.UTC().Format(time.RFC3339)
+}
+
+// Is makes errors.Is(err, ErrPasswordResetLocked) match.
+func (e *PasswordResetLockedError) Is(target error) bool {
+	return target == ErrPasswordResetLocked
+}
+
+// PasswordResetPolicyError is returned when a new password does not meet
+// the account's password policy.
+type PasswordResetPolicyError struct {
+	Code string // sysconfig.PasswordValidationError code
+}
+
+func (e *PasswordResetPolicyError) Error() string {
+	return "password does not meet the password policy: " + e.Code
+}
+
+// passwordResetMaxMinutes bounds the configurable durations to a week.
+const passwordResetMaxMinutes = 7 * 24 * 60
+
+// PasswordResetService runs the forgot-password flow of agents and
+// customers: it emails single-use reset links, limits how many an account
+// may request and locks out accounts that request too many.
+//
+// A link carries a random token; only its HMAC, keyed with the JWT secret
+// and bound to the account type, is stored, so neither a copy of the
+// database nor an agent link used on the customer portal resets a
+// password. Rotating the JWT secret invalidates the links sent before.
+type PasswordResetService struct {
+	db           *sql.DB
+	repo         *repository.PasswordResetRepository
+	policy       *PasswordPolicyService
+	mail         registrationMailQueue
+	key          []byte
+	agentFeature bool
+	from         string
+	baseURL      string
+	now          func() time.Time
+}
+
+// NewPasswordResetService creates a password reset service. Emails are
+// queued from email.from, with links to app.base_url; agent resets also
+// need features.lost_password.
+func NewPasswordResetService(db *sql.DB) *PasswordResetService {
+	s := &PasswordResetService{
+		db:     db,
+		repo:   repository.NewPasswordResetRepository(db),
+		policy: NewPasswordPolicyService(db),
+		mail:   mailqueue.NewMailQueueRepository(db),
+		key:    []byte(os.Getenv("JWT_SECRET")),
+		now:    time.Now,
+	}
+	if cfg := config.Get(); cfg != nil {
+		s.from = cfg.Email.From
+		s.baseURL = cfg.App.BaseURL
+		s.agentFeature = cfg.Features.LostPassword
+		if len(s.key) == 0 {
+			s.key = []byte(cfg.Auth.JWT.Secret)
+		}
+	}
+	return s
+}
+
+// Settings returns the password reset settings.
+func (s *PasswordResetService) Settings() (sysconfig.PasswordResetConfig, error) {
+	return sysconfig.LoadPasswordResetConfig(s.db)
+}
+
+// SaveSettings validates and stores the password reset settings. Zero
+// durations and limits take the defaults.
+func (s *PasswordResetService) SaveSettings(cfg sysconfig.PasswordResetConfig, userID int) (sysconfig.PasswordResetConfig, error) {
+	def := sysconfig.DefaultPasswordResetConfig()
+	for _, f := range []struct {
+		name     string
+		value    *int
+		fallback int
+		max      int
+	}{
+		{"token_valid_minutes", &cfg.TokenValidMinutes, def.TokenValidMinutes, passwordResetMaxMinutes},
+		{"max_requests", &cfg.MaxRequests, def.MaxRequests, 100},
+		{"window_minutes", &cfg.WindowMinutes, def.WindowMinutes, passwordResetMaxMinutes},
+		{"lockout_minutes", &cfg.LockoutMinutes, def.LockoutMinutes, passwordResetMaxMinutes},
+	} {
+		if *f.value == 0 {
+			*f.value = f.fallback
+		}
+		if *f.value < 1 || *f.value > f.max {
+			return cfg, fmt.Errorf("%w: %s must be between 1 and %d", ErrPasswordResetSettings, f.name, f.max)
+		}
+	}
+	if err := sysconfig.SavePasswordResetConfig(s.db, cfg, userID); err != nil {
+		return cfg, err
+	}
+	return cfg, nil
+}
+
+// Enabled tells whether accounts of the type may reset their password.
+func (s *PasswordResetService) Enabled(kind PasswordAccountType) (bool, error) {
+	cfg, err := s.Settings()
+	if err != nil {
+		return false, err
+	}
+	return s.enabled(cfg, kind)
+}
+
+func (s *PasswordResetService) enabled(cfg sysconfig.PasswordResetConfig, kind PasswordAccountType) (bool, error) {
+	switch kind {
+	case PasswordAccountAgent:
+		return cfg.Agents && s.agentFeature, nil
+	case PasswordAccountCustomer:
+		return cfg.Customers, nil
+	}
+	return false, ErrPasswordAccountType
+}
+
+// Request records a forgot-password request for an agent login, or a
+// customer login or email address, and emails a reset link when the account
+// exists, is valid and has an address. To not reveal which accounts exist
+// it succeeds either way; every request counts toward the limit, and one
+// beyond it locks the name out of resets.
+func (s *PasswordResetService) Request(ctx context.Context, kind PasswordAccountType, name, remoteIP string) error {
+	cfg, err := s.Settings()
+	if err != nil {
+		return err
+	}
+	if enabled, err := s.enabled(cfg, kind); err != nil || !enabled {
+		if err == nil {
+			err = ErrPasswordResetDisabled
+		}
+		return err
+	}
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > 200 {
+		return fmt.Errorf("%w: a login or email address is required", ErrPasswordResetInvalid)
+	}
+
+	acct, err := s.repo.Account(ctx, string(kind), name)
+	if err != nil {
+		return err
+	}
+	login := strings.ToLower(name)
+	if acct != nil {
+		login = acct.Login
+	}
+
+	now := s.now()
+	window := time.Duration(cfg.WindowMinutes) * time.Minute
+	if err := s.repo.DeleteBefore(ctx, now.Add(-window)); err != nil {
+		log.Printf("password reset: pruning requests failed: %v", err)
+	}
+	until, err := s.repo.LockedUntil(ctx, string(kind), login)
+	if err != nil {
+		return err
+	}
+	if until.After(now) {
+		return &PasswordResetLockedError{Until: until}
+	}
+	// Requests before a lockout ended do not count again.
+	since := now.Add(-window)
+	if until.After(since) {
+		since = until
+	}
+	n, err := s.repo.CountRequests(ctx, string(kind), login, since)
+	if err != nil {
+		return err
+	}
+	if n >= cfg.MaxRequests {
+		until = now.Add(time.Duration(cfg.LockoutMinutes) * time.Minute)
+		if err := s.repo.Lock(ctx, string(kind), login, until, now); err != nil {
+			return err
+		}
+		log.Printf("password reset: %s %q locked out until %s after %d requests from %s", kind, login, until.Format(time.RFC3339), n+1, remoteIP)
+		return &PasswordResetLockedError{Until: until}
+	}
+
+	req := &models.PasswordResetRequest{
+		UserType:   string(kind),
+		Login:      login,
+		RemoteIP:   remoteIP,
+		ExpiresAt:  now.Add(time.Duration(cfg.TokenValidMinutes) * time.Minute),
+		CreateTime: now,
+	}
+	if acct == nil || acct.ValidID != 1 || acct.Email == "" {
+		return s.repo.Create(ctx, req, "")
+	}
+
+	token, _, err := newSurveyToken()
+	if err != nil {
+		return err
+	}
+	if err := s.repo.Create(ctx, req, s.tokenHash(kind, token)); err != nil {
+		return err
+	}
+	body := fmt.Sprintf("Hello %s,\n\nsomeone, hopefully you, asked to reset the password of your account %s. "+
+		"Choose a new password here:\n\n%s\n\n"+
+		"The link is valid for %d minutes and works once. If you did not ask for it, ignore this email; your password stays unchanged.\n",
+		acct.FirstName, acct.Login, s.link(kind, "/reset-password/"+token), cfg.TokenValidMinutes)
+	return s.queueMail(ctx, acct.Email, "Reset your password", body, now)
+}
+
+// Check tells whether a reset link can still be used, without using it.
+func (s *PasswordResetService) Check(ctx context.Context, kind PasswordAccountType, token string) error {
+	_, err := s.open(ctx, kind, token)
+	return err
+}
+
+// Reset sets a new password with a reset link, which then stops working,
+// and tells the account's owner by email.
+func (s *PasswordResetService) Reset(ctx context.Context, kind PasswordAccountType, token, password string) error {
+	if enabled, err := s.Enabled(kind); err != nil || !enabled {
+		if err == nil {
+			err = ErrPasswordResetDisabled
+		}
+		return err
+	}
+	req, err := s.open(ctx, kind, token)
+	if err != nil {
+		return err
+	}
+	acct, err := s.repo.Account(ctx, req.UserType, req.Login)
+	if err != nil {
+		return err
+	}
+	if acct == nil || acct.ValidID != 1 {
+		return ErrPasswordResetLinkInvalid
+	}
+
+	if password == "" {
+		return fmt.Errorf("%w: a password is required", ErrPasswordResetInvalid)
+	}
+	verr, err := s.policy.CheckPassword(ctx, kind, acct.Login, password)
+	if err != nil {
+		return err
+	}
+	if verr != nil {
+		return &PasswordResetPolicyError{Code: verr.Code}
+	}
+	hash, err := auth.NewPasswordHasher().HashPassword(password)
+	if err != nil {
+		return fmt.Errorf("hash password: %w", err)
+	}
+
+	now := s.now()
+	if err := s.repo.Consume(ctx, req, hash, now); err != nil {
+		if errors.Is(err, repository.ErrPasswordResetUsed) {
+			return ErrPasswordResetLinkInvalid
+		}
+		return err
+	}
+	if err := s.policy.RecordChange(ctx, kind, acct.Login, hash); err != nil {
+		log.Printf("password reset: recording password history for %s %s failed: %v", kind, acct.Login, err)
+	}
+	if err := s.policy.RecordLoginSuccess(ctx, kind, acct.Login); err != nil {
+		log.Printf("password reset: clearing failed logins of %s %s failed: %v", kind, acct.Login, err)
+	}
+
+	if acct.Email != "" {
+		body := fmt.Sprintf("Hello %s,\n\nthe password of your account %s was just changed with a reset link. "+
+			"If you did not do this, contact us right away.\n", acct.FirstName, acct.Login)
+		if err := s.queueMail(ctx, acct.Email, "Your password was changed", body, now); err != nil {
+			log.Printf("password reset: queueing confirmation for %s %s failed: %v", kind, acct.Login, err)
+		}
+	}
+	return nil
+}
+
+// open returns the request of a usable reset link.
+func (s *PasswordResetService) open(ctx context.Context, kind PasswordAccountType, token string) (*models.PasswordResetRequest, error) {
+	if token == "" {
+		return nil, ErrPasswordResetLinkInvalid
+	}
+	req, err := s.repo.GetByTokenHash(ctx, s.tokenHash(kind, token))
+	if err != nil {
+		return nil, err
+	}
+	if req == nil || req.UserType != string(kind) || req.UsedAt != nil {
+		return nil, ErrPasswordResetLinkInvalid
+	}
+	if !s.now().Before(req.ExpiresAt) {
+		return nil, ErrPasswordResetLinkExpired
+	}
+	return req, nil
+}
+
+// Lockouts returns the accounts currently locked out of password resets.
+func (s *PasswordResetService) Lockouts(ctx context.Context) ([]models.PasswordResetLockout, error) {
+	return s.repo.Lockouts(ctx, s.now())
+}
+
+// Unlock ends the lockout of an account before its time.
+func (s *PasswordResetService) Unlock(ctx context.Context, kind PasswordAccountType, login string) error {
+	if kind != PasswordAccountAgent && kind != PasswordAccountCustomer {
+		return ErrPasswordAccountType
+	}
+	ok, err := s.repo.Unlock(ctx, string(kind), login)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrPasswordResetLockoutNotFound
+	}
+	return nil
+}
+
+// tokenHash signs a link token for the account type.
+func (s *PasswordResetService) tokenHash(kind PasswordAccountType, token string) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(string(kind) + ":" + token))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (s *PasswordResetService) queueMail(ctx context.Context, to, subject, body string, now time.Time) error {
+	sender := s.from
+	return s.mail.Insert(ctx, &mailqueue.MailQueueItem{
+		Sender:     &sender,
+		Recipient:  to,
+		RawMessage: mailqueue.BuildEmailMessage(s.from, to, subject, body),
+		CreateTime: now,
+	})
+}
+
+// link returns the URL of a page of the agent interface or, for customers,
+// the customer portal.
+func (s *PasswordResetService) link(kind PasswordAccountType, path string) string {
+	if kind == PasswordAccountCustomer {
+		path = "/customer" + path
+	}
+	return strings.TrimRight(s.baseURL, "/") + path
+}
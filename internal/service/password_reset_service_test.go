@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/auth"
+	"github.com/goatkit/goatflow/internal/sysconfig"
+	"github.com/goatkit/goatflow/internal/testutil"
+)
+
+func TestPasswordResetService(t *testing.T) {
+	db := testutil.UseMigratedDB(t)
+	for _, stmt := range []string{
+		`INSERT INTO users (login, pw, first_name, last_name, valid_id, create_time, create_by, change_time, change_by)
+			VALUES ('ann@example.com', 'old', 'Ann', 'Lee', 1, '2026-01-01 00:00:00', 1, '2026-01-01 00:00:00', 1)`,
+		`INSERT INTO users (login, pw, first_name, last_name, valid_id, create_time, create_by, change_time, change_by)
+			VALUES ('bob', 'old', 'Bob', 'Ray', 1, '2026-01-01 00:00:00', 1, '2026-01-01 00:00:00', 1)`,
+		`INSERT INTO customer_user (login, email, customer_id, pw, first_name, last_name, valid_id, create_time, create_by, change_time, change_by)
+			VALUES ('cust', 'Cust@Example.org', 'ACME', 'old', 'Cay', 'Doe', 1, '2026-01-01 00:00:00', 1, '2026-01-01 00:00:00', 1)`,
+	} {
+		_, err := db.Exec(stmt)
+		require.NoError(t, err)
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	mail := &stubMailQueue{}
+	svc := NewPasswordResetService(db)
+	svc.mail, svc.key = mail, []byte("secret")
+	svc.from, svc.baseURL = "support@example.com", "https://help.example.com/"
+	svc.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	assert.ErrorIs(t, svc.Request(ctx, PasswordAccountAgent, "ann@example.com", "203.0.113.9"), ErrPasswordResetDisabled,
+		"agents need features.lost_password")
+	svc.agentFeature = true
+
+	_, err := svc.SaveSettings(sysconfig.PasswordResetConfig{Agents: true, Customers: true, MaxRequests: 101}, 1)
+	assert.ErrorIs(t, err, ErrPasswordResetSettings)
+	saved, err := svc.SaveSettings(sysconfig.PasswordResetConfig{Agents: true, Customers: true, TokenValidMinutes: 30, MaxRequests: 2}, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 60, saved.LockoutMinutes)
+
+	link := regexp.MustCompile(`https://help\.example\.com/reset-password/([A-Za-z0-9_-]+)`)
+	require.NoError(t, svc.Request(ctx, PasswordAccountAgent, "ann@example.com", "203.0.113.9"))
+	require.Len(t, mail.items, 1)
+	assert.Equal(t, "ann@example.com", mail.items[0].Recipient)
+	m := link.FindStringSubmatch(string(mail.items[0].RawMessage))
+	require.Len(t, m, 2)
+	token := m[1]
+
+	// Logins that are no email address, and unknown ones, get no mail
+	require.NoError(t, svc.Request(ctx, PasswordAccountAgent, "bob", ""))
+	require.NoError(t, svc.Request(ctx, PasswordAccountAgent, "nobody@example.com", ""))
+	assert.Len(t, mail.items, 1)
+
+	assert.ErrorIs(t, svc.Check(ctx, PasswordAccountCustomer, token), ErrPasswordResetLinkInvalid, "agent link on the customer portal")
+	assert.ErrorIs(t, svc.Check(ctx, PasswordAccountAgent, token+"x"), ErrPasswordResetLinkInvalid)
+	svc.now = func() time.Time { return now.Add(31 * time.Minute) }
+	assert.ErrorIs(t, svc.Check(ctx, PasswordAccountAgent, token), ErrPasswordResetLinkExpired)
+	svc.now = func() time.Time { return now }
+	require.NoError(t, svc.Check(ctx, PasswordAccountAgent, token))
+
+	require.NoError(t, sysconfig.SaveAgentPasswordPolicy(db, sysconfig.PasswordPolicy{PasswordMinSize: 10}, 1))
+	var pwErr *PasswordResetPolicyError
+	require.ErrorAs(t, svc.Reset(ctx, PasswordAccountAgent, token, "short"), &pwErr)
+	assert.Equal(t, "min_size", pwErr.Code)
+
+	require.NoError(t, svc.Reset(ctx, PasswordAccountAgent, token, "correct horse"))
+	var pw string
+	require.NoError(t, db.QueryRow("SELECT pw FROM users WHERE login = 'ann@example.com'").Scan(&pw))
+	assert.True(t, auth.NewPasswordHasher().VerifyPassword("correct horse", pw))
+	require.Len(t, mail.items, 2)
+	assert.Contains(t, string(mail.items[1].RawMessage), "was just changed")
+	assert.ErrorIs(t, svc.Reset(ctx, PasswordAccountAgent, token, "another horse"), ErrPasswordResetLinkInvalid, "links work once")
+
+	// Customers are found by email too; a new link replaces the last one
+	clink := regexp.MustCompile(`https://help\.example\.com/customer/reset-password/([A-Za-z0-9_-]+)`)
+	require.NoError(t, svc.Request(ctx, PasswordAccountCustomer, "cust@example.org", ""))
+	require.NoError(t, svc.Request(ctx, PasswordAccountCustomer, "cust", ""))
+	require.Len(t, mail.items, 4)
+	assert.Equal(t, "Cust@Example.org", mail.items[3].Recipient)
+	first := clink.FindStringSubmatch(string(mail.items[2].RawMessage))
+	second := clink.FindStringSubmatch(string(mail.items[3].RawMessage))
+	require.Len(t, first, 2)
+	require.Len(t, second, 2)
+	assert.ErrorIs(t, svc.Check(ctx, PasswordAccountCustomer, first[1]), ErrPasswordResetLinkInvalid)
+	require.NoError(t, svc.Check(ctx, PasswordAccountCustomer, second[1]))
+
+	// One request beyond the limit locks the account out
+	var locked *PasswordResetLockedError
+	require.ErrorAs(t, svc.Request(ctx, PasswordAccountCustomer, "CUST@example.org", ""), &locked)
+	assert.Equal(t, now.Add(time.Hour), locked.Until)
+	assert.ErrorIs(t, svc.Request(ctx, PasswordAccountCustomer, "cust", ""), ErrPasswordResetLocked)
+	assert.Len(t, mail.items, 4)
+	lockouts, err := svc.Lockouts(ctx)
+	require.NoError(t, err)
+	require.Len(t, lockouts, 1)
+	assert.Equal(t, "cust", lockouts[0].Login)
+
+	// Requests before the lockout ended do not count again
+	svc.now = func() time.Time { return now.Add(61 * time.Minute) }
+	require.NoError(t, svc.Request(ctx, PasswordAccountCustomer, "cust", ""))
+	svc.now = func() time.Time { return now }
+
+	// Unknown names are limited alike
+	require.NoError(t, svc.Request(ctx, PasswordAccountAgent, "Nobody@example.com", ""))
+	assert.ErrorIs(t, svc.Request(ctx, PasswordAccountAgent, "nobody@example.com", ""), ErrPasswordResetLocked)
+	require.NoError(t, svc.Unlock(ctx, PasswordAccountAgent, "nobody@example.com"))
+	assert.ErrorIs(t, svc.Unlock(ctx, PasswordAccountAgent, "nobody@example.com"), ErrPasswordResetLockoutNotFound)
+}
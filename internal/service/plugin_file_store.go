@@ -0,0 +1,67 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"path/filepath"
+)
+
+// PluginFileStore keeps the files plugins store through the file_put,
+// file_get and file_delete host functions in the local attachment storage.
+// It implements plugin.FileStore. The database attachment backend only
+// holds article attachments, so plugin files always live on disk.
+type PluginFileStore struct {
+	storage *LocalStorageService
+}
+
+// NewPluginFileStore creates a plugin file store in storage. The host
+// passes paths under plugins/<name>/.
+func NewPluginFileStore(storage *LocalStorageService) *PluginFileStore {
+	return &PluginFileStore{storage: storage}
+}
+
+// bytesFile adapts stored content to the multipart.File Store takes.
+type bytesFile struct {
+	*bytes.Reader
+}
+
+func (bytesFile) Close() error { return nil }
+
+// Put stores content at path, replacing an existing file.
+func (s *PluginFileStore) Put(ctx context.Context, path string, content []byte) error {
+	header := &multipart.FileHeader{Filename: filepath.Base(path), Size: int64(len(content))}
+	_, err := s.storage.Store(ctx, bytesFile{bytes.NewReader(content)}, header, path)
+	return err
+}
+
+// Get returns the content of path, or false when it does not exist.
+func (s *PluginFileStore) Get(ctx context.Context, path string) ([]byte, bool, error) {
+	exists, err := s.storage.Exists(ctx, path)
+	if err != nil || !exists {
+		return nil, false, err
+	}
+	rc, err := s.storage.Retrieve(ctx, path)
+	if err != nil {
+		return nil, false, err
+	}
+	defer rc.Close()
+	content, err := io.ReadAll(rc)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read file: %w", err)
+	}
+	return content, true, nil
+}
+
+// Delete removes path. Deleting a missing file is not an error.
+func (s *PluginFileStore) Delete(ctx context.Context, path string) error {
+	return s.storage.Delete(ctx, path)
+}
+
+// Size returns the size of the file at path, or the total size of the files
+// under it when it is a directory; 0 when it does not exist.
+func (s *PluginFileStore) Size(ctx context.Context, path string) (int64, error) {
+	return s.storage.Usage(ctx, path)
+}
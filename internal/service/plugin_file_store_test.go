@@ -0,0 +1,48 @@
+package service
+
+import (
+	"context"
+	"testing"
+)
+
+func TestPluginFileStore(t *testing.T) {
+	storage, err := NewLocalStorageService(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := NewPluginFileStore(storage)
+	ctx := context.Background()
+
+	if err := store.Put(ctx, "plugins/stats/exports/a.csv", []byte("a,b")); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if err := store.Put(ctx, "plugins/stats/b.txt", []byte("hello")); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if content, found, err := store.Get(ctx, "plugins/stats/exports/a.csv"); err != nil || !found || string(content) != "a,b" {
+		t.Errorf("Get = %q, %v, %v", content, found, err)
+	}
+	if n, err := store.Size(ctx, "plugins/stats"); err != nil || n != 8 {
+		t.Errorf("Size(directory) = %d, %v; want 8", n, err)
+	}
+	if n, err := store.Size(ctx, "plugins/stats/b.txt"); err != nil || n != 5 {
+		t.Errorf("Size(file) = %d, %v; want 5", n, err)
+	}
+
+	if err := store.Put(ctx, "plugins/stats/b.txt", []byte("hi")); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if n, _ := store.Size(ctx, "plugins/stats/b.txt"); n != 2 {
+		t.Errorf("expected Put to replace the file, size %d", n)
+	}
+
+	if err := store.Delete(ctx, "plugins/stats/b.txt"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, found, err := store.Get(ctx, "plugins/stats/b.txt"); found || err != nil {
+		t.Errorf("Get after Delete = %v, %v; want a miss", found, err)
+	}
+	if n, err := store.Size(ctx, "plugins/missing"); err != nil || n != 0 {
+		t.Errorf("Size(missing) = %d, %v; want 0", n, err)
+	}
+}
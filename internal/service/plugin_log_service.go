@@ -0,0 +1,180 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/repository"
+)
+
+// ErrPluginLogQuery is returned for an invalid plugin log query.
+var ErrPluginLogQuery = errors.New("invalid plugin log query")
+
+const (
+	// PluginLogFlushInterval is how often Watch stores the entries it
+	// received.
+	PluginLogFlushInterval = 2 * time.Second
+	// PluginLogBatchSize stores a batch early once this many entries wait.
+	PluginLogBatchSize = 500
+	// PluginLogDefaultLimit is the number of entries returned when none is
+	// requested.
+	PluginLogDefaultLimit = 100
+	// PluginLogMaxLimit caps the entries returned by one query.
+	PluginLogMaxLimit = 1000
+)
+
+// pluginLogLevels orders the levels plugins log at.
+var pluginLogLevels = []string{"debug", "info", "warn", "error"}
+
+// PluginLogService stores plugin log entries and applies their retention.
+type PluginLogService struct {
+	repo *repository.PluginLogRepository
+	now  func() time.Time
+}
+
+// NewPluginLogService creates a plugin log service.
+func NewPluginLogService(db *sql.DB) *PluginLogService {
+	return &PluginLogService{
+		repo: repository.NewPluginLogRepository(db),
+		now:  time.Now,
+	}
+}
+
+// Record stores entries.
+func (s *PluginLogService) Record(ctx context.Context, entries []models.PluginLogEntry) error {
+	return s.repo.Insert(ctx, entries)
+}
+
+// Watch stores the entries received on entries every interval, or sooner
+// when a batch fills up, until ctx is done or entries is closed. What is
+// left is stored before it returns.
+func (s *PluginLogService) Watch(ctx context.Context, entries <-chan models.PluginLogEntry, interval time.Duration) {
+	batch := make([]models.PluginLogEntry, 0, PluginLogBatchSize)
+	flush := func(ctx context.Context) {
+		if err := s.Record(ctx, batch); err != nil {
+			log.Printf("plugin log: storing %d entries failed: %v", len(batch), err)
+		}
+		batch = batch[:0]
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case e, ok := <-entries:
+			if !ok {
+				flush(context.Background())
+				return
+			}
+			batch = append(batch, e)
+			if len(batch) >= PluginLogBatchSize {
+				flush(ctx)
+			}
+		case <-ticker.C:
+			flush(ctx)
+		case <-ctx.Done():
+			flushCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			flush(flushCtx)
+			cancel()
+			return
+		}
+	}
+}
+
+// Query returns a plugin's stored entries in [From, To), newest first.
+func (s *PluginLogService) Query(ctx context.Context, q models.PluginLogQuery) ([]models.PluginLogEntry, error) {
+	q, levels, err := checkPluginLogQuery(q)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.Query(ctx, q, levels)
+}
+
+// FilterPluginLogs applies q to entries held in memory, newest first, such
+// as those of the plugin log buffer when no database is available.
+func FilterPluginLogs(entries []models.PluginLogEntry, q models.PluginLogQuery) ([]models.PluginLogEntry, error) {
+	q, levels, err := checkPluginLogQuery(q)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]models.PluginLogEntry, 0)
+	for _, e := range entries {
+		if e.Plugin != q.Plugin || (levels != nil && !slices.Contains(levels, e.Level)) ||
+			(!q.From.IsZero() && e.Timestamp.Before(q.From)) || (!q.To.IsZero() && !e.Timestamp.Before(q.To)) {
+			continue
+		}
+		result = append(result, e)
+		if len(result) == q.Limit {
+			break
+		}
+	}
+	return result, nil
+}
+
+// checkPluginLogQuery applies the default limit and returns the levels to
+// include.
+func checkPluginLogQuery(q models.PluginLogQuery) (models.PluginLogQuery, []string, error) {
+	if q.Plugin == "" {
+		return q, nil, fmt.Errorf("%w: plugin is required", ErrPluginLogQuery)
+	}
+	if q.Limit == 0 {
+		q.Limit = PluginLogDefaultLimit
+	}
+	if q.Limit < 1 || q.Limit > PluginLogMaxLimit {
+		return q, nil, fmt.Errorf("%w: limit must be between 1 and %d", ErrPluginLogQuery, PluginLogMaxLimit)
+	}
+	if !q.From.IsZero() && !q.To.IsZero() && !q.From.Before(q.To) {
+		return q, nil, fmt.Errorf("%w: from must be before to", ErrPluginLogQuery)
+	}
+	levels, err := PluginLogLevelsFrom(q.Level)
+	return q, levels, err
+}
+
+// PluginLogLevelsFrom returns the levels at or above level; empty means all.
+func PluginLogLevelsFrom(level string) ([]string, error) {
+	if level == "" {
+		return nil, nil
+	}
+	for i, l := range pluginLogLevels {
+		if l == level {
+			return pluginLogLevels[i:], nil
+		}
+	}
+	return nil, fmt.Errorf("%w: unknown level %q", ErrPluginLogQuery, level)
+}
+
+// Prune removes entries past their retention: the plugin's entry in
+// pluginDays when it has one, defaultDays otherwise. Zero days keeps a
+// plugin's entries forever.
+func (s *PluginLogService) Prune(ctx context.Context, defaultDays int, pluginDays map[string]int) (int64, error) {
+	now := s.now()
+	names := make([]string, 0, len(pluginDays))
+	for name := range pluginDays {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var removed int64
+	for _, name := range names {
+		if pluginDays[name] < 1 {
+			continue
+		}
+		n, err := s.repo.DeleteBefore(ctx, name, now.AddDate(0, 0, -pluginDays[name]))
+		if err != nil {
+			return removed, err
+		}
+		removed += n
+	}
+	if defaultDays < 1 {
+		return removed, nil
+	}
+	n, err := s.repo.DeleteOthersBefore(ctx, names, now.AddDate(0, 0, -defaultDays))
+	return removed + n, err
+}
@@ -0,0 +1,115 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/testutil"
+)
+
+func TestPluginLogService(t *testing.T) {
+	db := testutil.UseMigratedDB(t)
+	ctx := context.Background()
+
+	now := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	svc := NewPluginLogService(db)
+	svc.now = func() time.Time { return now }
+
+	entry := func(plugin, level, msg string, age time.Duration) models.PluginLogEntry {
+		return models.PluginLogEntry{Timestamp: now.Add(-age), Plugin: plugin, Level: level, Message: msg}
+	}
+	stored := []models.PluginLogEntry{
+		entry("stats", "debug", "tick", time.Hour),
+		entry("stats", "error", "query failed", 2*time.Hour),
+		entry("stats", "info", "old", 40*24*time.Hour),
+		entry("hello", "info", "greeted", 20*24*time.Hour),
+	}
+	stored[1].Fields = map[string]any{"query": "SELECT 1"}
+	require.NoError(t, svc.Record(ctx, stored))
+
+	logs, err := svc.Query(ctx, models.PluginLogQuery{Plugin: "stats"})
+	require.NoError(t, err)
+	require.Len(t, logs, 3)
+	assert.Equal(t, "tick", logs[0].Message, "newest first")
+	assert.Equal(t, "SELECT 1", logs[1].Fields["query"])
+	assert.NotZero(t, logs[1].ID)
+
+	logs, err = svc.Query(ctx, models.PluginLogQuery{Plugin: "stats", Level: "info"})
+	require.NoError(t, err)
+	assert.Len(t, logs, 2, "debug is below info")
+
+	logs, err = svc.Query(ctx, models.PluginLogQuery{Plugin: "stats", From: now.Add(-3 * time.Hour), To: now.Add(-90 * time.Minute)})
+	require.NoError(t, err)
+	require.Len(t, logs, 1)
+	assert.Equal(t, "query failed", logs[0].Message)
+
+	for _, bad := range []models.PluginLogQuery{
+		{},
+		{Plugin: "stats", Limit: PluginLogMaxLimit + 1},
+		{Plugin: "stats", Level: "fatal"},
+		{Plugin: "stats", From: now, To: now.Add(-time.Hour)},
+	} {
+		_, err := svc.Query(ctx, bad)
+		assert.ErrorIs(t, err, ErrPluginLogQuery)
+	}
+
+	// stats keeps 30 days, everything else 14
+	removed, err := svc.Prune(ctx, 14, map[string]int{"stats": 30})
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), removed)
+	logs, err = svc.Query(ctx, models.PluginLogQuery{Plugin: "stats"})
+	require.NoError(t, err)
+	assert.Len(t, logs, 2)
+
+	removed, err = svc.Prune(ctx, 0, map[string]int{"stats": 0})
+	require.NoError(t, err)
+	assert.Zero(t, removed, "zero days keeps entries")
+}
+
+func TestPluginLogServiceWatch(t *testing.T) {
+	db := testutil.UseMigratedDB(t)
+	svc := NewPluginLogService(db)
+
+	entries := make(chan models.PluginLogEntry, 2)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		svc.Watch(context.Background(), entries, time.Hour)
+	}()
+	entries <- models.PluginLogEntry{Timestamp: time.Now(), Plugin: "stats", Level: "info", Message: "one"}
+	entries <- models.PluginLogEntry{Timestamp: time.Now(), Plugin: "stats", Level: "warn", Message: "two"}
+	close(entries)
+	<-done
+
+	logs, err := svc.Query(context.Background(), models.PluginLogQuery{Plugin: "stats"})
+	require.NoError(t, err)
+	assert.Len(t, logs, 2, "entries left are stored when the channel closes")
+}
+
+func TestFilterPluginLogs(t *testing.T) {
+	now := time.Now()
+	entries := []models.PluginLogEntry{
+		{Timestamp: now, Plugin: "stats", Level: "error", Message: "newest"},
+		{Timestamp: now.Add(-time.Minute), Plugin: "hello", Level: "error", Message: "other plugin"},
+		{Timestamp: now.Add(-2 * time.Minute), Plugin: "stats", Level: "debug", Message: "debug"},
+		{Timestamp: now.Add(-time.Hour), Plugin: "stats", Level: "warn", Message: "old"},
+	}
+
+	logs, err := FilterPluginLogs(entries, models.PluginLogQuery{Plugin: "stats", Level: "warn"})
+	require.NoError(t, err)
+	require.Len(t, logs, 2)
+	assert.Equal(t, "newest", logs[0].Message)
+
+	logs, err = FilterPluginLogs(entries, models.PluginLogQuery{Plugin: "stats", From: now.Add(-10 * time.Minute), Limit: 1})
+	require.NoError(t, err)
+	require.Len(t, logs, 1)
+	assert.Equal(t, "newest", logs[0].Message)
+
+	_, err = FilterPluginLogs(entries, models.PluginLogQuery{Plugin: "stats", Level: "loud"})
+	assert.ErrorIs(t, err, ErrPluginLogQuery)
+}
@@ -0,0 +1,394 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/repository"
+)
+
+// Certificate hook actions.
+const (
+	PortalCertificateProvision = "provision"
+	PortalCertificateRevoke    = "revoke"
+)
+
+// portalDomainCacheTTL bounds how long a host lookup is reused. Changes on
+// this instance clear the cache at once; other instances see them after
+// the TTL.
+const portalDomainCacheTTL = 30 * time.Second
+
+// Errors returned by PortalDomainService.
+var (
+	ErrPortalDomainNotFound   = errors.New("portal domain not found")
+	ErrPortalDomainInvalid    = errors.New("domain must be a fully qualified host name")
+	ErrPortalDomainExists     = errors.New("domain is already used by another portal")
+	ErrPortalDomainCompany    = errors.New("customer company not found")
+	ErrPortalDomainTheme      = errors.New("theme must be a theme ID of lowercase letters, digits and dashes")
+	ErrPortalDomainLogo       = errors.New("logo_url must be an https URL or a path starting with /")
+	ErrPortalDomainProvider   = errors.New("sso_provider_id must be a customer sign-in provider")
+	ErrPortalDomainLogin      = errors.New("password login can only be disabled when an identity provider is set")
+	ErrPortalDomainQueue      = errors.New("queue_ids must be valid queues")
+	ErrPortalDomainCertStatus = errors.New("status must be one of pending, requested, issued, failed")
+)
+
+var (
+	portalHostLabel = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+	portalThemeID   = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,99}$`)
+)
+
+// PortalCertificateHook asks the system that manages TLS certificates to
+// provision or revoke the certificate of a portal domain.
+type PortalCertificateHook func(ctx context.Context, action, domain string) error
+
+// NewPortalCertificateWebhook returns a hook that POSTs
+// {"action": ..., "domain": ...} to url. With a secret the body is signed
+// with HMAC-SHA256 in the X-GoatFlow-Signature header.
+func NewPortalCertificateWebhook(url, secret string, timeout time.Duration) PortalCertificateHook {
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	client := &http.Client{Timeout: timeout}
+	return func(ctx context.Context, action, domain string) error {
+		body, err := json.Marshal(map[string]string{"action": action, "domain": domain})
+		if err != nil {
+			return err
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if secret != "" {
+			mac := hmac.New(sha256.New, []byte(secret))
+			mac.Write(body)
+			req.Header.Set("X-GoatFlow-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			return fmt.Errorf("certificate hook answered %s", resp.Status)
+		}
+		return nil
+	}
+}
+
+type portalDomainCacheEntry struct {
+	domain  *models.PortalDomain
+	expires time.Time
+}
+
+// portalDomainCache maps normalized hosts to their portal domain, including
+// hosts that are not portal domains, so the main host costs no query.
+var portalDomainCache sync.Map
+
+func clearPortalDomainCache() {
+	portalDomainCache.Range(func(key, _ interface{}) bool {
+		portalDomainCache.Delete(key)
+		return true
+	})
+}
+
+// PortalDomainService manages custom domains serving a branded customer
+// portal for one customer company: the theme and logo, the identity
+// provider offered at sign-in, the queues whose tickets are shown, and the
+// TLS certificate requested through the certificate hook.
+type PortalDomainService struct {
+	repo *repository.PortalDomainRepository
+	sso  *repository.SSORepository
+	hook PortalCertificateHook
+	now  func() time.Time
+}
+
+// NewPortalDomainService creates a portal domain service without a
+// certificate hook.
+func NewPortalDomainService(db *sql.DB) *PortalDomainService {
+	return &PortalDomainService{
+		repo: repository.NewPortalDomainRepository(db),
+		sso:  repository.NewSSORepository(db),
+		now:  time.Now,
+	}
+}
+
+// SetCertificateHook sets the hook run when domains are added, renamed or
+// removed.
+func (s *PortalDomainService) SetCertificateHook(hook PortalCertificateHook) {
+	s.hook = hook
+}
+
+// List returns every portal domain.
+func (s *PortalDomainService) List(ctx context.Context) ([]models.PortalDomain, error) {
+	return s.repo.List(ctx)
+}
+
+// Get returns a portal domain by ID.
+func (s *PortalDomainService) Get(ctx context.Context, id int) (*models.PortalDomain, error) {
+	d, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if d == nil {
+		return nil, ErrPortalDomainNotFound
+	}
+	return d, nil
+}
+
+// Create validates and stores a portal domain, then requests its
+// certificate.
+func (s *PortalDomainService) Create(ctx context.Context, d *models.PortalDomain, userID int) (*models.PortalDomain, error) {
+	if err := s.validate(ctx, d); err != nil {
+		return nil, err
+	}
+	id, err := s.repo.Create(ctx, d, userID)
+	if err != nil {
+		return nil, err
+	}
+	clearPortalDomainCache()
+	if d.ValidID == 1 {
+		s.requestCertificate(ctx, id, PortalCertificateProvision, d.Domain)
+	}
+	return s.Get(ctx, id)
+}
+
+// Update validates and stores changes to a portal domain. Renaming a domain
+// requests a certificate for the new name and revokes the old one.
+func (s *PortalDomainService) Update(ctx context.Context, d *models.PortalDomain, userID int) (*models.PortalDomain, error) {
+	old, err := s.Get(ctx, d.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.validate(ctx, d); err != nil {
+		return nil, err
+	}
+	if err := s.repo.Update(ctx, d, userID); errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrPortalDomainNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	clearPortalDomainCache()
+	if old.Domain != d.Domain {
+		s.runHook(ctx, PortalCertificateRevoke, old.Domain)
+	}
+	if d.ValidID == 1 && (old.Domain != d.Domain || old.ValidID != 1) {
+		s.requestCertificate(ctx, d.ID, PortalCertificateProvision, d.Domain)
+	}
+	return s.Get(ctx, d.ID)
+}
+
+// Delete removes a portal domain and revokes its certificate.
+func (s *PortalDomainService) Delete(ctx context.Context, id int) error {
+	d, err := s.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.Delete(ctx, id); errors.Is(err, sql.ErrNoRows) {
+		return ErrPortalDomainNotFound
+	} else if err != nil {
+		return err
+	}
+	clearPortalDomainCache()
+	s.runHook(ctx, PortalCertificateRevoke, d.Domain)
+	return nil
+}
+
+// RequestCertificate runs the certificate hook for the domain again, for
+// example after a failure.
+func (s *PortalDomainService) RequestCertificate(ctx context.Context, id int) (*models.PortalDomain, error) {
+	d, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	s.requestCertificate(ctx, id, PortalCertificateProvision, d.Domain)
+	return s.Get(ctx, id)
+}
+
+// ReportCertificate records the certificate state reported by the
+// provisioning system.
+func (s *PortalDomainService) ReportCertificate(ctx context.Context, id int, status models.PortalCertStatus, message string) (*models.PortalDomain, error) {
+	if !status.IsValid() {
+		return nil, ErrPortalDomainCertStatus
+	}
+	if len(message) > 500 {
+		message = message[:500]
+	}
+	if err := s.repo.SetCertificateStatus(ctx, id, status, message); errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrPortalDomainNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return s.Get(ctx, id)
+}
+
+// requestCertificate runs the provision hook and records the outcome. A
+// failing hook never fails the change that triggered it.
+func (s *PortalDomainService) requestCertificate(ctx context.Context, id int, action, domain string) {
+	status, message := models.PortalCertPending, "No certificate hook is configured"
+	if s.hook != nil {
+		if err := s.hook(ctx, action, domain); err != nil {
+			log.Printf("portal domain %s: certificate hook failed: %v", domain, err)
+			status, message = models.PortalCertFailed, err.Error()
+		} else {
+			status, message = models.PortalCertRequested, ""
+		}
+	}
+	if len(message) > 500 {
+		message = message[:500]
+	}
+	if err := s.repo.SetCertificateStatus(ctx, id, status, message); err != nil {
+		log.Printf("portal domain %s: %v", domain, err)
+	}
+}
+
+func (s *PortalDomainService) runHook(ctx context.Context, action, domain string) {
+	if s.hook == nil {
+		return
+	}
+	if err := s.hook(ctx, action, domain); err != nil {
+		log.Printf("portal domain %s: certificate hook (%s) failed: %v", domain, action, err)
+	}
+}
+
+func (s *PortalDomainService) validate(ctx context.Context, d *models.PortalDomain) error {
+	d.Domain = models.NormalizePortalHost(d.Domain)
+	if !validPortalHost(d.Domain) {
+		return ErrPortalDomainInvalid
+	}
+	if exists, err := s.repo.DomainExists(ctx, d.Domain, d.ID); err != nil {
+		return err
+	} else if exists {
+		return ErrPortalDomainExists
+	}
+
+	d.CustomerID = strings.TrimSpace(d.CustomerID)
+	if d.CustomerID == "" {
+		return ErrPortalDomainCompany
+	}
+	if ok, err := s.repo.CompanyExists(ctx, d.CustomerID); err != nil {
+		return err
+	} else if !ok {
+		return ErrPortalDomainCompany
+	}
+
+	d.Theme = strings.TrimSpace(d.Theme)
+	if d.Theme != "" && !portalThemeID.MatchString(d.Theme) {
+		return ErrPortalDomainTheme
+	}
+	d.LogoURL = strings.TrimSpace(d.LogoURL)
+	if d.LogoURL != "" && !validPortalLogo(d.LogoURL) {
+		return ErrPortalDomainLogo
+	}
+
+	if d.SSOProviderID < 0 {
+		return ErrPortalDomainProvider
+	}
+	if d.SSOProviderID > 0 {
+		p, err := s.sso.Get(ctx, d.SSOProviderID)
+		if err != nil {
+			return err
+		}
+		if p == nil || p.Frontend != models.SSOFrontendCustomer {
+			return ErrPortalDomainProvider
+		}
+	} else if !d.PasswordLogin {
+		return ErrPortalDomainLogin
+	}
+
+	queues := make([]int, 0, len(d.QueueIDs))
+	seen := map[int]bool{}
+	for _, id := range d.QueueIDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		if ok, err := s.repo.QueueExists(ctx, id); err != nil {
+			return err
+		} else if !ok {
+			return ErrPortalDomainQueue
+		}
+		queues = append(queues, id)
+	}
+	d.QueueIDs = queues
+
+	if d.ValidID == 0 {
+		d.ValidID = 1
+	}
+	return nil
+}
+
+func validPortalHost(host string) bool {
+	if len(host) > 253 || net.ParseIP(host) != nil {
+		return false
+	}
+	labels := strings.Split(host, ".")
+	if len(labels) < 2 {
+		return false
+	}
+	for _, label := range labels {
+		if !portalHostLabel.MatchString(label) {
+			return false
+		}
+	}
+	return true
+}
+
+func validPortalLogo(logo string) bool {
+	if len(logo) > 500 || strings.ContainsAny(logo, "\"'<> ") {
+		return false
+	}
+	if strings.HasPrefix(logo, "/") {
+		return !strings.HasPrefix(logo, "//")
+	}
+	return strings.HasPrefix(logo, "https://") && len(logo) > len("https://")
+}
+
+// Resolve returns the enabled portal domain serving the request host, or
+// nil when the host is not a portal domain.
+func (s *PortalDomainService) Resolve(ctx context.Context, host string) (*models.PortalDomain, error) {
+	host = models.NormalizePortalHost(host)
+	if host == "" {
+		return nil, nil
+	}
+	now := s.now()
+	if v, ok := portalDomainCache.Load(host); ok {
+		if entry := v.(portalDomainCacheEntry); now.Before(entry.expires) {
+			return entry.domain, nil
+		}
+	}
+	d, err := s.repo.GetByDomain(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	if d != nil && d.ValidID != 1 {
+		d = nil
+	}
+	portalDomainCache.Store(host, portalDomainCacheEntry{domain: d, expires: now.Add(portalDomainCacheTTL)})
+	return d, nil
+}
+
+// AllowsCustomer reports whether the customer user belongs to the company
+// the portal domain serves.
+func (s *PortalDomainService) AllowsCustomer(ctx context.Context, d *models.PortalDomain, login string) (bool, error) {
+	company, err := s.repo.CustomerCompany(ctx, login)
+	if err != nil {
+		return false, err
+	}
+	return company != "" && strings.EqualFold(company, d.CustomerID), nil
+}
@@ -0,0 +1,156 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/testutil"
+)
+
+func newPortalDomainTestService(t *testing.T) (*PortalDomainService, *[]string) {
+	t.Helper()
+	db := testutil.MigratedDB(t)
+	clearPortalDomainCache()
+	t.Cleanup(clearPortalDomainCache)
+
+	for _, stmt := range []string{
+		`INSERT INTO users (id, login, pw, first_name, last_name, valid_id, create_time, create_by, change_time, change_by)
+			VALUES (1, 'root@localhost', 'x', 'Admin', 'OTRS', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+		`INSERT INTO customer_company (customer_id, name, valid_id, create_time, create_by, change_time, change_by)
+			VALUES ('acme', 'Acme', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1),
+			       ('globex', 'Globex', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+		`INSERT INTO customer_user (login, email, customer_id, pw, first_name, last_name, valid_id,
+			create_time, create_by, change_time, change_by)
+			VALUES ('wile', 'wile@acme.test', 'acme', 'x', 'Wile', 'Coyote', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1),
+			       ('hank', 'hank@globex.test', 'globex', 'x', 'Hank', 'Scorpio', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+		`UPDATE queue SET valid_id = 2 WHERE id = 3`,
+		`INSERT INTO sso_provider (id, name, protocol, frontend, config, valid_id, create_time, create_by, change_time, change_by) VALUES
+			(1, 'Acme Okta', 'oidc', 'customer', '{}', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1),
+			(2, 'Staff', 'oidc', 'agent', '{}', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+	} {
+		_, err := db.Exec(stmt)
+		require.NoError(t, err, stmt)
+	}
+
+	svc := NewPortalDomainService(db)
+	var calls []string
+	svc.SetCertificateHook(func(_ context.Context, action, domain string) error {
+		calls = append(calls, action+" "+domain)
+		if domain == "broken.example.com" {
+			return errors.New("acme rate limited")
+		}
+		return nil
+	})
+	return svc, &calls
+}
+
+func TestPortalDomainService_Validate(t *testing.T) {
+	svc, _ := newPortalDomainTestService(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		name   string
+		domain models.PortalDomain
+		want   error
+	}{
+		{"single label", models.PortalDomain{Domain: "localhost", CustomerID: "acme", PasswordLogin: true}, ErrPortalDomainInvalid},
+		{"ip address", models.PortalDomain{Domain: "10.0.0.1", CustomerID: "acme", PasswordLogin: true}, ErrPortalDomainInvalid},
+		{"bad label", models.PortalDomain{Domain: "-acme.example.com", CustomerID: "acme", PasswordLogin: true}, ErrPortalDomainInvalid},
+		{"unknown company", models.PortalDomain{Domain: "help.example.com", CustomerID: "initech", PasswordLogin: true}, ErrPortalDomainCompany},
+		{"bad theme", models.PortalDomain{Domain: "help.example.com", CustomerID: "acme", Theme: "../x", PasswordLogin: true}, ErrPortalDomainTheme},
+		{"http logo", models.PortalDomain{Domain: "help.example.com", CustomerID: "acme", LogoURL: "http://x.test/l.png", PasswordLogin: true}, ErrPortalDomainLogo},
+		{"protocol relative logo", models.PortalDomain{Domain: "help.example.com", CustomerID: "acme", LogoURL: "//x.test/l.png", PasswordLogin: true}, ErrPortalDomainLogo},
+		{"agent provider", models.PortalDomain{Domain: "help.example.com", CustomerID: "acme", SSOProviderID: 2, PasswordLogin: true}, ErrPortalDomainProvider},
+		{"no way to sign in", models.PortalDomain{Domain: "help.example.com", CustomerID: "acme"}, ErrPortalDomainLogin},
+		{"invalid queue", models.PortalDomain{Domain: "help.example.com", CustomerID: "acme", QueueIDs: []int{3}, PasswordLogin: true}, ErrPortalDomainQueue},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := tt.domain
+			_, err := svc.Create(ctx, &d, 1)
+			assert.ErrorIs(t, err, tt.want)
+		})
+	}
+}
+
+func TestPortalDomainService_CreateAndResolve(t *testing.T) {
+	svc, calls := newPortalDomainTestService(t)
+	ctx := context.Background()
+
+	d, err := svc.Create(ctx, &models.PortalDomain{
+		Domain: "Support.Acme.example.com.", CustomerID: "acme", Theme: "seventies-vibes",
+		LogoURL: "https://cdn.acme.test/logo.svg", SSOProviderID: 1, QueueIDs: []int{2, 1, 2},
+	}, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "support.acme.example.com", d.Domain)
+	assert.Equal(t, []int{2, 1}, d.QueueIDs, "queues keep their order without duplicates")
+	assert.False(t, d.PasswordLogin)
+	assert.Equal(t, models.PortalCertRequested, d.CertStatus)
+	assert.Equal(t, []string{"provision support.acme.example.com"}, *calls)
+
+	_, err = svc.Create(ctx, &models.PortalDomain{Domain: "support.acme.example.com", CustomerID: "globex", PasswordLogin: true}, 1)
+	assert.ErrorIs(t, err, ErrPortalDomainExists)
+
+	resolved, err := svc.Resolve(ctx, "SUPPORT.acme.example.com:8443")
+	require.NoError(t, err)
+	require.NotNil(t, resolved)
+	assert.Equal(t, d.ID, resolved.ID)
+	assert.True(t, resolved.AllowsQueue(2))
+	assert.False(t, resolved.AllowsQueue(3))
+
+	none, err := svc.Resolve(ctx, "goatflow.example.com")
+	require.NoError(t, err)
+	assert.Nil(t, none)
+
+	ok, err := svc.AllowsCustomer(ctx, resolved, "wile")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = svc.AllowsCustomer(ctx, resolved, "hank")
+	require.NoError(t, err)
+	assert.False(t, ok, "customers of other companies cannot use the portal")
+
+	// Disabling the domain stops it resolving at once.
+	d.ValidID = 2
+	d.PasswordLogin = true
+	_, err = svc.Update(ctx, d, 1)
+	require.NoError(t, err)
+	resolved, err = svc.Resolve(ctx, "support.acme.example.com")
+	require.NoError(t, err)
+	assert.Nil(t, resolved)
+}
+
+func TestPortalDomainService_Certificates(t *testing.T) {
+	svc, calls := newPortalDomainTestService(t)
+	ctx := context.Background()
+
+	d, err := svc.Create(ctx, &models.PortalDomain{Domain: "broken.example.com", CustomerID: "acme", PasswordLogin: true}, 1)
+	require.NoError(t, err, "a failing hook does not stop the domain being added")
+	assert.Equal(t, models.PortalCertFailed, d.CertStatus)
+	assert.Equal(t, "acme rate limited", d.CertMessage)
+
+	d.Domain = "help.acme.example.com"
+	d, err = svc.Update(ctx, d, 1)
+	require.NoError(t, err)
+	assert.Equal(t, models.PortalCertRequested, d.CertStatus)
+
+	d, err = svc.ReportCertificate(ctx, d.ID, models.PortalCertIssued, "")
+	require.NoError(t, err)
+	assert.Equal(t, models.PortalCertIssued, d.CertStatus)
+	assert.NotNil(t, d.CertChangeTime)
+	_, err = svc.ReportCertificate(ctx, d.ID, "done", "")
+	assert.ErrorIs(t, err, ErrPortalDomainCertStatus)
+
+	require.NoError(t, svc.Delete(ctx, d.ID))
+	assert.ErrorIs(t, svc.Delete(ctx, d.ID), ErrPortalDomainNotFound)
+	assert.Equal(t, []string{
+		"provision broken.example.com",
+		"revoke broken.example.com",
+		"provision help.acme.example.com",
+		"revoke help.acme.example.com",
+	}, *calls)
+}
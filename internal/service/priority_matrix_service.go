@@ -0,0 +1,298 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/repository"
+)
+
+// Errors returned by PriorityMatrixService.
+var (
+	ErrPriorityMatrixLevelNotFound   = errors.New("impact or urgency level not found")
+	ErrPriorityMatrixLevelNameExists = errors.New("a level of this kind with this name already exists")
+	ErrPriorityMatrixLevelInUse      = errors.New("level is still used by tickets")
+	ErrPriorityMatrixQueueNotFound   = errors.New("queue not found")
+	ErrPriorityMatrixInvalid         = errors.New("invalid priority matrix")
+)
+
+// priorityMatrixMaxLevels caps the levels of one kind, and so the size of
+// a matrix.
+const priorityMatrixMaxLevels = 20
+
+// PriorityMatrixService manages the impact and urgency levels tickets are
+// rated with and the matrix deriving a ticket's priority from its rating.
+// Queues may override cells of the default matrix.
+type PriorityMatrixService struct {
+	repo *repository.PriorityMatrixRepository
+	now  func() time.Time
+}
+
+// NewPriorityMatrixService creates a priority matrix service.
+func NewPriorityMatrixService(db *sql.DB) *PriorityMatrixService {
+	return &PriorityMatrixService{repo: repository.NewPriorityMatrixRepository(db), now: time.Now}
+}
+
+// ListLevels returns the levels of a kind, or of both kinds when kind is
+// empty; validOnly skips invalid ones.
+func (s *PriorityMatrixService) ListLevels(ctx context.Context, kind string, validOnly bool) ([]*models.PriorityMatrixLevel, error) {
+	if kind != "" && kind != models.PriorityMatrixImpact && kind != models.PriorityMatrixUrgency {
+		return nil, fmt.Errorf("%w: kind must be impact or urgency", ErrPriorityMatrixInvalid)
+	}
+	return s.repo.ListLevels(ctx, kind, validOnly)
+}
+
+// GetLevel returns a level.
+func (s *PriorityMatrixService) GetLevel(ctx context.Context, id int) (*models.PriorityMatrixLevel, error) {
+	level, err := s.repo.GetLevel(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if level == nil {
+		return nil, ErrPriorityMatrixLevelNotFound
+	}
+	return level, nil
+}
+
+// CreateLevel validates and stores a new level.
+func (s *PriorityMatrixService) CreateLevel(ctx context.Context, level *models.PriorityMatrixLevel, userID int) error {
+	if level.Kind != models.PriorityMatrixImpact && level.Kind != models.PriorityMatrixUrgency {
+		return fmt.Errorf("%w: kind must be impact or urgency", ErrPriorityMatrixInvalid)
+	}
+	if err := s.validateLevel(ctx, level); err != nil {
+		return err
+	}
+	existing, err := s.repo.ListLevels(ctx, level.Kind, false)
+	if err != nil {
+		return err
+	}
+	if len(existing) >= priorityMatrixMaxLevels {
+		return fmt.Errorf("%w: at most %d %s levels", ErrPriorityMatrixInvalid, priorityMatrixMaxLevels, level.Kind)
+	}
+	now := s.now()
+	level.CreateTime, level.CreateBy = now, userID
+	level.ChangeTime, level.ChangeBy = now, userID
+	return s.repo.CreateLevel(ctx, level)
+}
+
+// UpdateLevel validates and stores changes to a level. The kind of a level
+// cannot change.
+func (s *PriorityMatrixService) UpdateLevel(ctx context.Context, level *models.PriorityMatrixLevel, userID int) error {
+	existing, err := s.GetLevel(ctx, level.ID)
+	if err != nil {
+		return err
+	}
+	level.Kind = existing.Kind
+	if err := s.validateLevel(ctx, level); err != nil {
+		return err
+	}
+	level.CreateTime, level.CreateBy = existing.CreateTime, existing.CreateBy
+	level.ChangeTime, level.ChangeBy = s.now(), userID
+	if err := s.repo.UpdateLevel(ctx, level); err == sql.ErrNoRows {
+		return ErrPriorityMatrixLevelNotFound
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+// DeleteLevel deletes a level no ticket is rated with, together with its
+// matrix cells. Levels in use can be set invalid instead.
+func (s *PriorityMatrixService) DeleteLevel(ctx context.Context, id int) error {
+	if _, err := s.GetLevel(ctx, id); err != nil {
+		return err
+	}
+	if n, err := s.repo.CountLevelTickets(ctx, id); err != nil {
+		return err
+	} else if n > 0 {
+		return fmt.Errorf("%w: %d tickets", ErrPriorityMatrixLevelInUse, n)
+	}
+	deleted, err := s.repo.DeleteLevel(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrPriorityMatrixLevelNotFound
+	}
+	return nil
+}
+
+// GetMatrix returns the valid levels and the effective cells of a queue's
+// matrix: the queue's own cells, and the default matrix's cells where the
+// queue has none. queueID 0 returns the default matrix.
+func (s *PriorityMatrixService) GetMatrix(ctx context.Context, queueID int) (*models.PriorityMatrix, error) {
+	if err := s.checkQueue(ctx, queueID); err != nil {
+		return nil, err
+	}
+	matrix := &models.PriorityMatrix{
+		QueueID:   queueID,
+		Impacts:   []models.PriorityMatrixLevel{},
+		Urgencies: []models.PriorityMatrixLevel{},
+	}
+	levels, err := s.repo.ListLevels(ctx, "", true)
+	if err != nil {
+		return nil, err
+	}
+	for _, level := range levels {
+		if level.Kind == models.PriorityMatrixImpact {
+			matrix.Impacts = append(matrix.Impacts, *level)
+		} else {
+			matrix.Urgencies = append(matrix.Urgencies, *level)
+		}
+	}
+
+	cells, err := s.repo.ListCells(ctx, 0)
+	if err != nil {
+		return nil, err
+	}
+	if queueID > 0 {
+		own, err := s.repo.ListCells(ctx, queueID)
+		if err != nil {
+			return nil, err
+		}
+		index := make(map[[2]int]int, len(cells))
+		for i, cell := range cells {
+			index[[2]int{cell.ImpactID, cell.UrgencyID}] = i
+		}
+		for _, cell := range own {
+			if i, ok := index[[2]int{cell.ImpactID, cell.UrgencyID}]; ok {
+				cells[i] = cell
+			} else {
+				cells = append(cells, cell)
+			}
+		}
+	}
+	matrix.Cells = cells
+	return matrix, nil
+}
+
+// SetMatrix replaces the cells of a queue's matrix, or of the default
+// matrix when queueID is 0. An empty list removes a queue's overrides.
+func (s *PriorityMatrixService) SetMatrix(ctx context.Context, queueID int, cells []models.PriorityMatrixCell, userID int) error {
+	if err := s.checkQueue(ctx, queueID); err != nil {
+		return err
+	}
+	levels, err := s.repo.ListLevels(ctx, "", false)
+	if err != nil {
+		return err
+	}
+	kinds := make(map[int]string, len(levels))
+	for _, level := range levels {
+		kinds[level.ID] = level.Kind
+	}
+	seen := make(map[[2]int]bool, len(cells))
+	priorities := map[int]bool{}
+	for i := range cells {
+		cell := &cells[i]
+		cell.QueueID = queueID
+		if kinds[cell.ImpactID] != models.PriorityMatrixImpact {
+			return fmt.Errorf("%w: unknown impact %d", ErrPriorityMatrixInvalid, cell.ImpactID)
+		}
+		if kinds[cell.UrgencyID] != models.PriorityMatrixUrgency {
+			return fmt.Errorf("%w: unknown urgency %d", ErrPriorityMatrixInvalid, cell.UrgencyID)
+		}
+		key := [2]int{cell.ImpactID, cell.UrgencyID}
+		if seen[key] {
+			return fmt.Errorf("%w: duplicate cell for impact %d and urgency %d", ErrPriorityMatrixInvalid, cell.ImpactID, cell.UrgencyID)
+		}
+		seen[key] = true
+		if !priorities[cell.PriorityID] {
+			ok, err := s.repo.PriorityExists(ctx, cell.PriorityID)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return fmt.Errorf("%w: unknown priority %d", ErrPriorityMatrixInvalid, cell.PriorityID)
+			}
+			priorities[cell.PriorityID] = true
+		}
+	}
+	return s.repo.ReplaceCells(ctx, queueID, cells, userID, s.now())
+}
+
+// Derive returns the priority a queue's matrix maps the impact and urgency
+// to, or 0 when either is unset or the matrix has no such cell.
+func (s *PriorityMatrixService) Derive(ctx context.Context, queueID, impactID, urgencyID int) (int, error) {
+	if impactID <= 0 || urgencyID <= 0 {
+		return 0, nil
+	}
+	return s.repo.LookupPriority(ctx, queueID, impactID, urgencyID)
+}
+
+// CheckLevel returns ErrPriorityMatrixInvalid unless id is 0 or a valid
+// level of the kind. Callers check the levels a request sets before
+// passing them to RateTicket.
+func (s *PriorityMatrixService) CheckLevel(ctx context.Context, kind string, id int) error {
+	if id == 0 {
+		return nil
+	}
+	level, err := s.repo.GetLevel(ctx, id)
+	if err != nil {
+		return err
+	}
+	if level == nil || level.Kind != kind || level.ValidID != 1 {
+		return fmt.Errorf("%w: unknown %s %d", ErrPriorityMatrixInvalid, kind, id)
+	}
+	return nil
+}
+
+// TicketRating returns the impact and urgency of a ticket; unrated tickets
+// get zero IDs.
+func (s *PriorityMatrixService) TicketRating(ctx context.Context, ticketID int64) (*models.TicketImpactUrgency, error) {
+	rating, err := s.repo.GetTicketRating(ctx, ticketID)
+	if err != nil {
+		return nil, err
+	}
+	if rating == nil {
+		rating = &models.TicketImpactUrgency{TicketID: ticketID}
+	}
+	return rating, nil
+}
+
+// RateTicket stores the impact and urgency of a ticket.
+func (s *PriorityMatrixService) RateTicket(ctx context.Context, rating *models.TicketImpactUrgency, userID int) error {
+	return s.repo.SetTicketRating(ctx, rating, userID, s.now())
+}
+
+func (s *PriorityMatrixService) validateLevel(ctx context.Context, level *models.PriorityMatrixLevel) error {
+	level.Name = strings.TrimSpace(level.Name)
+	if level.Name == "" || len(level.Name) > 200 {
+		return fmt.Errorf("%w: name must be 1 to 200 characters", ErrPriorityMatrixInvalid)
+	}
+	if level.ValidID == 0 {
+		level.ValidID = 1
+	}
+	if level.ValidID != 1 && level.ValidID != 2 {
+		return fmt.Errorf("%w: valid_id must be 1 or 2", ErrPriorityMatrixInvalid)
+	}
+	exists, err := s.repo.LevelNameExists(ctx, level.Kind, level.Name, level.ID)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return ErrPriorityMatrixLevelNameExists
+	}
+	return nil
+}
+
+func (s *PriorityMatrixService) checkQueue(ctx context.Context, queueID int) error {
+	if queueID < 0 {
+		return ErrPriorityMatrixQueueNotFound
+	}
+	if queueID == 0 {
+		return nil
+	}
+	ok, err := s.repo.QueueExists(ctx, queueID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrPriorityMatrixQueueNotFound
+	}
+	return nil
+}
@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/testutil"
+)
+
+func TestPriorityMatrixService(t *testing.T) {
+	db := testutil.UseMigratedDB(t)
+	_, err := db.Exec(`INSERT INTO users (id, login, pw, first_name, last_name, valid_id, create_time, create_by, change_time, change_by)
+		VALUES (1, 'root@localhost', 'x', 'Admin', 'OTRS', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`)
+	require.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO ticket (id, tn, title, queue_id, ticket_lock_id, user_id, responsible_user_id,
+		ticket_priority_id, ticket_state_id, timeout, until_time, escalation_time, escalation_update_time,
+		escalation_response_time, escalation_solution_time, archive_flag, create_time, create_by, change_time, change_by)
+		VALUES (1, '2026031010000001', 'Mail down', 1, 1, 1, 1, 3, 1, 0, 0, 0, 0, 0, 0, 0,
+		        CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	s := NewPriorityMatrixService(db)
+	s.now = func() time.Time { return time.Date(2026, 3, 10, 9, 0, 0, 0, time.UTC) }
+
+	for name, level := range map[string]*models.PriorityMatrixLevel{
+		"no kind":      {Name: "High"},
+		"unknown kind": {Kind: "severity", Name: "High"},
+		"no name":      {Kind: models.PriorityMatrixImpact, Name: " "},
+		"bad valid id": {Kind: models.PriorityMatrixImpact, Name: "High", ValidID: 3},
+	} {
+		assert.ErrorIs(t, s.CreateLevel(ctx, level, 1), ErrPriorityMatrixInvalid, name)
+	}
+
+	create := func(kind, name string, position int) *models.PriorityMatrixLevel {
+		l := &models.PriorityMatrixLevel{Kind: kind, Name: name, Position: position}
+		require.NoError(t, s.CreateLevel(ctx, l, 1))
+		return l
+	}
+	high := create(models.PriorityMatrixImpact, " High ", 1)
+	assert.Equal(t, "High", high.Name)
+	assert.Equal(t, 1, high.ValidID)
+	low := create(models.PriorityMatrixImpact, "Low", 2)
+	urgent := create(models.PriorityMatrixUrgency, "High", 1)
+	later := create(models.PriorityMatrixUrgency, "Low", 2)
+	assert.ErrorIs(t, s.CreateLevel(ctx, &models.PriorityMatrixLevel{Kind: models.PriorityMatrixImpact, Name: "Low"}, 1),
+		ErrPriorityMatrixLevelNameExists)
+
+	update := &models.PriorityMatrixLevel{ID: later.ID, Kind: models.PriorityMatrixImpact, Name: "Medium", Position: 2}
+	require.NoError(t, s.UpdateLevel(ctx, update, 1))
+	assert.Equal(t, models.PriorityMatrixUrgency, update.Kind, "the kind cannot change")
+	assert.ErrorIs(t, s.UpdateLevel(ctx, &models.PriorityMatrixLevel{ID: 9999, Name: "x"}, 1), ErrPriorityMatrixLevelNotFound)
+
+	_, err = s.ListLevels(ctx, "severity", false)
+	assert.ErrorIs(t, err, ErrPriorityMatrixInvalid)
+
+	for name, cells := range map[string][]models.PriorityMatrixCell{
+		"urgency as impact": {{ImpactID: urgent.ID, UrgencyID: later.ID, PriorityID: 3}},
+		"impact as urgency": {{ImpactID: high.ID, UrgencyID: low.ID, PriorityID: 3}},
+		"unknown priority":  {{ImpactID: high.ID, UrgencyID: urgent.ID, PriorityID: 99}},
+		"duplicate cell": {
+			{ImpactID: high.ID, UrgencyID: urgent.ID, PriorityID: 5},
+			{ImpactID: high.ID, UrgencyID: urgent.ID, PriorityID: 4},
+		},
+	} {
+		assert.ErrorIs(t, s.SetMatrix(ctx, 0, cells, 1), ErrPriorityMatrixInvalid, name)
+	}
+	assert.ErrorIs(t, s.SetMatrix(ctx, 9999, nil, 1), ErrPriorityMatrixQueueNotFound)
+	_, err = s.GetMatrix(ctx, 9999)
+	assert.ErrorIs(t, err, ErrPriorityMatrixQueueNotFound)
+
+	require.NoError(t, s.SetMatrix(ctx, 0, []models.PriorityMatrixCell{
+		{ImpactID: high.ID, UrgencyID: urgent.ID, PriorityID: 5},
+		{ImpactID: high.ID, UrgencyID: later.ID, PriorityID: 4},
+		{ImpactID: low.ID, UrgencyID: urgent.ID, PriorityID: 3},
+		{ImpactID: low.ID, UrgencyID: later.ID, PriorityID: 1},
+	}, 1))
+	require.NoError(t, s.SetMatrix(ctx, 2, []models.PriorityMatrixCell{
+		{ImpactID: low.ID, UrgencyID: later.ID, PriorityID: 2},
+	}, 1))
+
+	matrix, err := s.GetMatrix(ctx, 2)
+	require.NoError(t, err)
+	assert.Len(t, matrix.Impacts, 2)
+	assert.Len(t, matrix.Urgencies, 2)
+	require.Len(t, matrix.Cells, 4)
+	for _, cell := range matrix.Cells {
+		if cell.ImpactID == low.ID && cell.UrgencyID == later.ID {
+			assert.Equal(t, models.PriorityMatrixCell{QueueID: 2, ImpactID: low.ID, UrgencyID: later.ID, PriorityID: 2}, cell)
+		} else {
+			assert.Zero(t, cell.QueueID, "other cells come from the default matrix")
+		}
+	}
+
+	derived, err := s.Derive(ctx, 2, low.ID, later.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 2, derived)
+	derived, err = s.Derive(ctx, 1, low.ID, later.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, derived)
+	derived, err = s.Derive(ctx, 1, high.ID, 0)
+	require.NoError(t, err)
+	assert.Zero(t, derived, "both ratings are needed")
+
+	assert.NoError(t, s.CheckLevel(ctx, models.PriorityMatrixImpact, 0))
+	assert.NoError(t, s.CheckLevel(ctx, models.PriorityMatrixImpact, high.ID))
+	assert.ErrorIs(t, s.CheckLevel(ctx, models.PriorityMatrixUrgency, high.ID), ErrPriorityMatrixInvalid)
+	assert.ErrorIs(t, s.CheckLevel(ctx, models.PriorityMatrixImpact, 9999), ErrPriorityMatrixInvalid)
+
+	rating, err := s.TicketRating(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, &models.TicketImpactUrgency{TicketID: 1}, rating)
+	rating.ImpactID, rating.UrgencyID = high.ID, urgent.ID
+	require.NoError(t, s.RateTicket(ctx, rating, 1))
+
+	assert.ErrorIs(t, s.DeleteLevel(ctx, high.ID), ErrPriorityMatrixLevelInUse)
+	require.NoError(t, s.DeleteLevel(ctx, low.ID))
+	assert.ErrorIs(t, s.DeleteLevel(ctx, low.ID), ErrPriorityMatrixLevelNotFound)
+	matrix, err = s.GetMatrix(ctx, 0)
+	require.NoError(t, err)
+	assert.Len(t, matrix.Cells, 2)
+}
@@ -0,0 +1,502 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/goatkit/goatflow/internal/jobqueue"
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/repository"
+)
+
+// PrivacyJobType is the job queue type that runs approved privacy requests.
+const PrivacyJobType = "privacy.request"
+
+// erasedText replaces erased ticket titles and article subjects.
+const erasedText = "[erased]"
+
+// Errors returned by PrivacyService.
+var (
+	ErrPrivacyRequestNotFound = errors.New("privacy request not found")
+	ErrPrivacySubjectNotFound = errors.New("no customer user or ticket matches the login or email")
+	ErrPrivacyKind            = errors.New("kind must be one of export, erasure")
+	ErrPrivacyFieldPolicy     = errors.New("invalid field policy")
+	ErrPrivacyNotPending      = errors.New("privacy request is no longer pending")
+	ErrPrivacyNotApproved     = errors.New("privacy request is not approved")
+	ErrPrivacySelfApproval    = errors.New("erasures must be approved by an admin other than the requester")
+	ErrPrivacyExportNotReady  = errors.New("export is not available")
+)
+
+// privacyJob is the payload of a PrivacyJobType job.
+type privacyJob struct {
+	RequestID int `json:"request_id"`
+}
+
+// PrivacyService handles data subject requests for customer users: exports
+// of everything stored about them and erasures that pseudonymize or erase
+// it field by field. Requests wait for an admin's approval and then run as
+// background jobs; every step is written to the request's audit trail.
+type PrivacyService struct {
+	repo *repository.PrivacyRepository
+	// enqueue starts an approved request and returns the job ID; tests
+	// replace it.
+	enqueue func(ctx context.Context, requestID int) (string, error)
+	now     func() time.Time
+}
+
+// NewPrivacyService creates a privacy service. Approved requests go to the
+// default job queue, or run in the background of this process when the
+// job queue is disabled.
+func NewPrivacyService(db *sql.DB) *PrivacyService {
+	s := &PrivacyService{
+		repo: repository.NewPrivacyRepository(db),
+		now:  time.Now,
+	}
+	s.enqueue = s.enqueueJob
+	return s
+}
+
+func (s *PrivacyService) enqueueJob(ctx context.Context, requestID int) (string, error) {
+	job, err := jobqueue.Default().Enqueue(ctx, PrivacyJobType, privacyJob{RequestID: requestID}, jobqueue.MaxAttempts(3))
+	if errors.Is(err, jobqueue.ErrNoQueue) {
+		go func() {
+			if err := s.Execute(context.Background(), requestID); err != nil {
+				log.Printf("privacy: request %d failed: %v", requestID, err)
+			}
+		}()
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return job.ID, nil
+}
+
+// HandleJob runs the privacy request of a PrivacyJobType job.
+func (s *PrivacyService) HandleJob(ctx context.Context, job *jobqueue.Job) error {
+	var payload privacyJob
+	if err := job.Decode(&payload); err != nil {
+		return jobqueue.Permanent(err)
+	}
+	err := s.Execute(ctx, payload.RequestID)
+	if errors.Is(err, ErrPrivacyRequestNotFound) || errors.Is(err, ErrPrivacyNotApproved) {
+		return jobqueue.Permanent(err)
+	}
+	return err
+}
+
+// CreateRequest records a pending request for the customer user with the
+// login or email address subject. The field policy of an erasure is merged
+// over the defaults; exports ignore it.
+func (s *PrivacyService) CreateRequest(ctx context.Context, kind models.PrivacyRequestKind, subject string,
+	policy map[string]models.PrivacyFieldAction, reason string, userID int) (*models.PrivacyRequest, error) {
+	switch kind {
+	case models.PrivacyRequestExport:
+		policy = nil
+	case models.PrivacyRequestErasure:
+		merged, err := mergePrivacyFieldPolicy(policy)
+		if err != nil {
+			return nil, err
+		}
+		policy = merged
+	default:
+		return nil, ErrPrivacyKind
+	}
+
+	subject = strings.TrimSpace(subject)
+	if subject == "" {
+		return nil, ErrPrivacySubjectNotFound
+	}
+	login, err := s.repo.FindCustomerLogin(ctx, subject)
+	if err != nil {
+		return nil, err
+	}
+	if login == "" {
+		return nil, ErrPrivacySubjectNotFound
+	}
+
+	req := &models.PrivacyRequest{
+		Kind:          kind,
+		Subject:       subject,
+		CustomerLogin: login,
+		FieldPolicy:   policy,
+		Reason:        strings.TrimSpace(reason),
+		Status:        models.PrivacyStatusPending,
+		RequestedBy:   userID,
+		RequestedAt:   s.now(),
+	}
+	id, err := s.repo.CreateRequest(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	s.audit(ctx, id, "created", fmt.Sprintf("%s of %s", kind, login), userID)
+	return s.Get(ctx, id)
+}
+
+// Get returns a request by ID.
+func (s *PrivacyService) Get(ctx context.Context, id int) (*models.PrivacyRequest, error) {
+	req, err := s.repo.GetRequest(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if req == nil {
+		return nil, ErrPrivacyRequestNotFound
+	}
+	return req, nil
+}
+
+// List returns the most recent requests, optionally only those with status.
+func (s *PrivacyService) List(ctx context.Context, status models.PrivacyRequestStatus, limit int) ([]*models.PrivacyRequest, error) {
+	return s.repo.ListRequests(ctx, status, limit)
+}
+
+// Logs returns the audit trail of a request.
+func (s *PrivacyService) Logs(ctx context.Context, id int) ([]*models.PrivacyRequestLog, error) {
+	if _, err := s.Get(ctx, id); err != nil {
+		return nil, err
+	}
+	return s.repo.ListLogs(ctx, id)
+}
+
+// Approve approves a pending request and queues it. Erasures cannot be
+// approved by the admin who requested them.
+func (s *PrivacyService) Approve(ctx context.Context, id, userID int, comment string) (*models.PrivacyRequest, error) {
+	req, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if req.Status != models.PrivacyStatusPending {
+		return nil, ErrPrivacyNotPending
+	}
+	if req.Kind == models.PrivacyRequestErasure && req.RequestedBy == userID {
+		return nil, ErrPrivacySelfApproval
+	}
+	if err := s.decide(ctx, id, models.PrivacyStatusApproved, userID, comment); err != nil {
+		return nil, err
+	}
+
+	jobID, err := s.enqueue(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("queue privacy request: %w", err)
+	}
+	if jobID != "" {
+		if err := s.repo.SetJobID(ctx, id, jobID); err != nil {
+			return nil, err
+		}
+		s.audit(ctx, id, "queued", "job "+jobID, userID)
+	}
+	return s.Get(ctx, id)
+}
+
+// Reject rejects a pending request.
+func (s *PrivacyService) Reject(ctx context.Context, id, userID int, comment string) (*models.PrivacyRequest, error) {
+	if _, err := s.Get(ctx, id); err != nil {
+		return nil, err
+	}
+	if err := s.decide(ctx, id, models.PrivacyStatusRejected, userID, comment); err != nil {
+		return nil, err
+	}
+	return s.Get(ctx, id)
+}
+
+func (s *PrivacyService) decide(ctx context.Context, id int, status models.PrivacyRequestStatus, userID int, comment string) error {
+	comment = strings.TrimSpace(comment)
+	if err := s.repo.Decide(ctx, id, status, userID, comment, s.now()); err != nil {
+		if errors.Is(err, repository.ErrPrivacyRequestNotPending) {
+			return ErrPrivacyNotPending
+		}
+		return err
+	}
+	s.audit(ctx, id, string(status), comment, userID)
+	return nil
+}
+
+// Execute runs an approved request. Completed requests are left alone, so
+// a job delivered twice does no harm; failed ones are run again.
+func (s *PrivacyService) Execute(ctx context.Context, id int) error {
+	req, err := s.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	switch req.Status {
+	case models.PrivacyStatusCompleted:
+		return nil
+	case models.PrivacyStatusApproved, models.PrivacyStatusRunning, models.PrivacyStatusFailed:
+	default:
+		return ErrPrivacyNotApproved
+	}
+	actor := req.RequestedBy
+	if req.DecidedBy != nil {
+		actor = *req.DecidedBy
+	}
+
+	if err := s.repo.StartRequest(ctx, id, s.now()); err != nil {
+		return err
+	}
+	s.audit(ctx, id, "started", "", actor)
+
+	var result map[string]int
+	if req.Kind == models.PrivacyRequestErasure {
+		result, err = s.erase(ctx, req, actor)
+	} else {
+		result, err = s.export(ctx, req)
+	}
+	if err != nil {
+		if ferr := s.repo.FinishRequest(ctx, id, models.PrivacyStatusFailed, nil, err.Error(), s.now()); ferr != nil {
+			log.Printf("privacy: recording failure of request %d failed: %v", id, ferr)
+		}
+		s.audit(ctx, id, "failed", err.Error(), actor)
+		return err
+	}
+	if err := s.repo.FinishRequest(ctx, id, models.PrivacyStatusCompleted, result, "", s.now()); err != nil {
+		return err
+	}
+	s.audit(ctx, id, "completed", formatPrivacyResult(result), actor)
+	return nil
+}
+
+// Export returns the ZIP of a completed export and records the download.
+func (s *PrivacyService) Export(ctx context.Context, id, userID int) (string, []byte, error) {
+	req, err := s.Get(ctx, id)
+	if err != nil {
+		return "", nil, err
+	}
+	if req.Kind != models.PrivacyRequestExport || req.Status != models.PrivacyStatusCompleted {
+		return "", nil, ErrPrivacyExportNotReady
+	}
+	filename, content, err := s.repo.GetExport(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil, ErrPrivacyExportNotReady
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	s.audit(ctx, id, "downloaded", filename, userID)
+	return filename, content, nil
+}
+
+// export writes the customer user's record and preferences, tickets,
+// articles, ticket history and attachments to a ZIP stored with the
+// request.
+func (s *PrivacyService) export(ctx context.Context, req *models.PrivacyRequest) (map[string]int, error) {
+	user, prefs, err := s.repo.CustomerData(ctx, req.CustomerLogin)
+	if err != nil {
+		return nil, err
+	}
+	tickets, articles, history, err := s.repo.TicketData(ctx, req.CustomerLogin)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	result := map[string]int{"tickets": len(tickets), "articles": len(articles), "history": len(history)}
+	files := []struct {
+		name string
+		data interface{}
+	}{
+		{"manifest.json", map[string]interface{}{
+			"request_id":     req.ID,
+			"subject":        req.Subject,
+			"customer_login": req.CustomerLogin,
+			"generated_at":   s.now().UTC(),
+		}},
+		{"customer.json", map[string]interface{}{"customer_user": user, "preferences": prefs}},
+		{"tickets.json", tickets},
+		{"articles.json", articles},
+		{"history.json", history},
+	}
+	for _, f := range files {
+		w, err := zw.Create(f.name)
+		if err != nil {
+			return nil, err
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(f.data); err != nil {
+			return nil, fmt.Errorf("write %s: %w", f.name, err)
+		}
+	}
+
+	err = s.repo.EachAttachment(ctx, req.CustomerLogin, func(tn string, articleID int64, filename string, content []byte) error {
+		name := path.Join("attachments", safeZipName(tn), fmt.Sprint(articleID), safeZipName(filename))
+		w, err := zw.Create(name)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(content); err != nil {
+			return fmt.Errorf("write %s: %w", name, err)
+		}
+		result["attachments"]++
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	filename := fmt.Sprintf("privacy-export-%d-%s.zip", req.ID, s.now().Format("20060102"))
+	if err := s.repo.SaveExport(ctx, req.ID, filename, buf.Bytes()); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// erase applies the request's field policy. Pseudonyms are derived from the
+// request ID, so the customer can only be identified again through the
+// request itself.
+func (s *PrivacyService) erase(ctx context.Context, req *models.PrivacyRequest, userID int) (map[string]int, error) {
+	policy, err := mergePrivacyFieldPolicy(req.FieldPolicy)
+	if err != nil {
+		return nil, err
+	}
+	user, _, err := s.repo.CustomerData(ctx, req.CustomerLogin)
+	if err != nil {
+		return nil, err
+	}
+
+	pseudonym := fmt.Sprintf("anon-%d", req.ID)
+	pseudoEmail := pseudonym + "@invalid"
+	e := &repository.PrivacyErasure{Login: req.CustomerLogin, Customer: map[string]interface{}{}}
+
+	if policy["customer.login"] == models.PrivacyPseudonymize {
+		e.NewLogin = pseudonym
+	}
+	switch policy["customer.name"] {
+	case models.PrivacyPseudonymize:
+		e.Customer["first_name"], e.Customer["last_name"], e.Customer["title"] = "Anonymous", pseudonym, nil
+	case models.PrivacyErase:
+		e.Customer["first_name"], e.Customer["last_name"], e.Customer["title"] = "", "", nil
+	}
+	switch policy["customer.email"] {
+	case models.PrivacyPseudonymize:
+		e.Customer["email"] = pseudoEmail
+	case models.PrivacyErase:
+		e.Customer["email"] = ""
+	}
+	if policy["customer.phone"] != models.PrivacyKeep {
+		value := eraseOrPseudonym(policy["customer.phone"], pseudonym)
+		e.Customer["phone"], e.Customer["mobile"], e.Customer["fax"] = value, value, value
+	}
+	if policy["customer.address"] != models.PrivacyKeep {
+		value := eraseOrPseudonym(policy["customer.address"], pseudonym)
+		e.Customer["street"], e.Customer["zip"], e.Customer["city"], e.Customer["country"] = value, value, value, value
+	}
+	if policy["customer.comments"] == models.PrivacyErase {
+		e.Customer["comments"] = nil
+	}
+	if user == nil {
+		e.Customer = nil
+	}
+	e.DeletePreferences = policy["customer.preferences"] == models.PrivacyErase
+
+	if policy["ticket.title"] == models.PrivacyErase {
+		title := erasedText
+		e.TicketTitle = &title
+	}
+	if policy["article.subject"] == models.PrivacyErase {
+		subject := erasedText
+		e.ArticleSubject = &subject
+	}
+	e.ClearArticleBody = policy["article.body"] == models.PrivacyErase
+	e.DeleteAttachments = policy["article.attachments"] == models.PrivacyErase
+	if policy["article.addresses"] == models.PrivacyPseudonymize {
+		e.AddressReplacements = privacyAddressReplacements(req.CustomerLogin, user, pseudonym, pseudoEmail)
+	}
+
+	return s.repo.Erase(ctx, e, userID, s.now())
+}
+
+// privacyAddressReplacements returns the old, new pairs that pseudonymize
+// the customer in article address headers: the email address, a login
+// that is one, and the full name.
+func privacyAddressReplacements(login string, user map[string]interface{}, pseudonym, pseudoEmail string) []string {
+	var pairs []string
+	if user != nil {
+		if email := fmt.Sprint(user["email"]); strings.Contains(email, "@") {
+			pairs = append(pairs, email, pseudoEmail)
+		}
+	}
+	if strings.Contains(login, "@") {
+		pairs = append(pairs, login, pseudoEmail)
+	}
+	if user != nil {
+		first, _ := user["first_name"].(string)
+		last, _ := user["last_name"].(string)
+		if name := strings.TrimSpace(first + " " + last); strings.Contains(name, " ") {
+			pairs = append(pairs, name, pseudonym)
+		}
+	}
+	return pairs
+}
+
+func eraseOrPseudonym(action models.PrivacyFieldAction, pseudonym string) interface{} {
+	if action == models.PrivacyPseudonymize {
+		return pseudonym
+	}
+	return nil
+}
+
+// mergePrivacyFieldPolicy returns the default field policy overridden by
+// policy, rejecting unknown fields and unsupported actions.
+func mergePrivacyFieldPolicy(policy map[string]models.PrivacyFieldAction) (map[string]models.PrivacyFieldAction, error) {
+	merged := models.DefaultPrivacyFieldPolicy()
+	for name, action := range policy {
+		field, ok := privacyField(name)
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown field %q", ErrPrivacyFieldPolicy, name)
+		}
+		supported := false
+		for _, a := range field.Actions {
+			supported = supported || a == action
+		}
+		if !supported {
+			return nil, fmt.Errorf("%w: %s does not support %q", ErrPrivacyFieldPolicy, name, action)
+		}
+		merged[name] = action
+	}
+	return merged, nil
+}
+
+func privacyField(name string) (models.PrivacyField, bool) {
+	for _, f := range models.PrivacyFields {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return models.PrivacyField{}, false
+}
+
+// audit adds an entry to the request's audit trail. Failures are logged
+// but do not stop the request.
+func (s *PrivacyService) audit(ctx context.Context, id int, action, message string, userID int) {
+	log.Printf("privacy: request %d %s by user %d", id, action, userID)
+	if err := s.repo.AddLog(ctx, id, action, message, userID); err != nil {
+		log.Printf("privacy: audit log for request %d failed: %v", id, err)
+	}
+}
+
+func formatPrivacyResult(result map[string]int) string {
+	data, _ := json.Marshal(result)
+	return string(data)
+}
+
+// safeZipName keeps a path element from escaping its directory.
+func safeZipName(name string) string {
+	name = strings.NewReplacer("/", "_", "\\", "_").Replace(name)
+	if name == "" || name == "." || name == ".." {
+		return "unnamed"
+	}
+	return name
+}
@@ -0,0 +1,161 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/testutil"
+)
+
+func newPrivacyTestService(t *testing.T) (*PrivacyService, *sql.DB) {
+	t.Helper()
+	db := testutil.UseMigratedDB(t)
+	now := time.Now()
+	for _, stmt := range []string{
+		`INSERT INTO users (id, login, pw, first_name, last_name, valid_id, create_time, create_by, change_time, change_by)
+			VALUES (1, 'admin', 'x', 'A', 'Admin', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1),
+			(2, 'dpo', 'x', 'D', 'Officer', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+		`INSERT INTO customer_user (login, email, customer_id, first_name, last_name, phone, valid_id,
+			create_time, create_by, change_time, change_by)
+			VALUES ('jane', 'jane@example.com', 'acme', 'Jane', 'Doe', '555-1234', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+	} {
+		_, err := db.Exec(stmt)
+		require.NoError(t, err, stmt)
+	}
+	_, err := db.Exec(`INSERT INTO ticket (id, tn, title, queue_id, ticket_lock_id, user_id, responsible_user_id,
+		ticket_priority_id, ticket_state_id, customer_user_id, timeout, until_time, escalation_time, escalation_update_time,
+		escalation_response_time, escalation_solution_time, create_time, create_by, change_time, change_by)
+		VALUES (1, '2025010100001', 'Printer', 1, 1, 1, 1, 3, 4, 'jane', 0, 0, 0, 0, 0, 0, ?, 1, ?, 1)`, now, now)
+	require.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO article (id, ticket_id, article_sender_type_id, communication_channel_id,
+		is_visible_for_customer, create_time, create_by, change_time, change_by) VALUES (10, 1, 3, 1, 1, ?, 1, ?, 1)`, now, now)
+	require.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO article_data_mime (article_id, a_from, a_subject, a_body, incoming_time,
+		create_time, create_by, change_time, change_by) VALUES (10, 'Jane Doe <jane@example.com>', 'Printer', 'It is on fire', 0, ?, 1, ?, 1)`, now, now)
+	require.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO article_data_mime_attachment (article_id, filename, content, create_time, create_by,
+		change_time, change_by) VALUES (10, '../smoke.jpg', ?, ?, 1, ?, 1)`, []byte{0xff, 0xd8}, now, now)
+	require.NoError(t, err)
+
+	svc := NewPrivacyService(db)
+	svc.enqueue = func(ctx context.Context, requestID int) (string, error) { return "job-1", nil }
+	return svc, db
+}
+
+func TestPrivacyService_CreateRequest(t *testing.T) {
+	svc, _ := newPrivacyTestService(t)
+	ctx := context.Background()
+
+	_, err := svc.CreateRequest(ctx, "delete", "jane", nil, "", 1)
+	assert.ErrorIs(t, err, ErrPrivacyKind)
+	_, err = svc.CreateRequest(ctx, models.PrivacyRequestExport, "nobody@example.com", nil, "", 1)
+	assert.ErrorIs(t, err, ErrPrivacySubjectNotFound)
+	_, err = svc.CreateRequest(ctx, models.PrivacyRequestErasure, "jane", map[string]models.PrivacyFieldAction{"customer.shoe_size": models.PrivacyErase}, "", 1)
+	assert.ErrorIs(t, err, ErrPrivacyFieldPolicy)
+	_, err = svc.CreateRequest(ctx, models.PrivacyRequestErasure, "jane", map[string]models.PrivacyFieldAction{"article.body": models.PrivacyPseudonymize}, "", 1)
+	assert.ErrorIs(t, err, ErrPrivacyFieldPolicy)
+
+	req, err := svc.CreateRequest(ctx, models.PrivacyRequestErasure, "JANE@example.com", map[string]models.PrivacyFieldAction{"ticket.title": models.PrivacyErase}, "Art. 17", 1)
+	require.NoError(t, err)
+	assert.Equal(t, "jane", req.CustomerLogin)
+	assert.Equal(t, models.PrivacyStatusPending, req.Status)
+	assert.Equal(t, models.PrivacyErase, req.FieldPolicy["ticket.title"])
+	assert.Equal(t, models.PrivacyPseudonymize, req.FieldPolicy["customer.name"], "defaults are filled in")
+
+	_, err = svc.Approve(ctx, req.ID, 1, "")
+	assert.ErrorIs(t, err, ErrPrivacySelfApproval)
+	assert.ErrorIs(t, svc.Execute(ctx, req.ID), ErrPrivacyNotApproved)
+
+	rejected, err := svc.Reject(ctx, req.ID, 2, "no legal basis")
+	require.NoError(t, err)
+	assert.Equal(t, models.PrivacyStatusRejected, rejected.Status)
+	_, err = svc.Approve(ctx, req.ID, 2, "")
+	assert.ErrorIs(t, err, ErrPrivacyNotPending)
+}
+
+func TestPrivacyService_Export(t *testing.T) {
+	svc, _ := newPrivacyTestService(t)
+	ctx := context.Background()
+
+	req, err := svc.CreateRequest(ctx, models.PrivacyRequestExport, "jane", nil, "", 1)
+	require.NoError(t, err)
+	_, _, err = svc.Export(ctx, req.ID, 1)
+	assert.ErrorIs(t, err, ErrPrivacyExportNotReady)
+
+	approved, err := svc.Approve(ctx, req.ID, 1, "")
+	require.NoError(t, err)
+	assert.Equal(t, "job-1", approved.JobID)
+	require.NoError(t, svc.Execute(ctx, req.ID))
+
+	done, err := svc.Get(ctx, req.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.PrivacyStatusCompleted, done.Status)
+	assert.Equal(t, 1, done.Result["tickets"])
+	assert.Equal(t, 1, done.Result["attachments"])
+
+	name, content, err := svc.Export(ctx, req.ID, 1)
+	require.NoError(t, err)
+	assert.Contains(t, name, "privacy-export-")
+	zr, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	require.NoError(t, err)
+	var names []string
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+	}
+	assert.ElementsMatch(t, []string{"manifest.json", "customer.json", "tickets.json", "articles.json", "history.json",
+		"attachments/2025010100001/10/.._smoke.jpg"}, names)
+
+	logs, err := svc.Logs(ctx, req.ID)
+	require.NoError(t, err)
+	var actions []string
+	for _, l := range logs {
+		actions = append(actions, l.Action)
+	}
+	assert.Equal(t, []string{"created", "approved", "queued", "started", "completed", "downloaded"}, actions)
+}
+
+func TestPrivacyService_Erasure(t *testing.T) {
+	svc, db := newPrivacyTestService(t)
+	ctx := context.Background()
+
+	req, err := svc.CreateRequest(ctx, models.PrivacyRequestErasure, "jane", nil, "", 1)
+	require.NoError(t, err)
+	_, err = svc.Approve(ctx, req.ID, 2, "verified identity")
+	require.NoError(t, err)
+	require.NoError(t, svc.Execute(ctx, req.ID))
+	require.NoError(t, svc.Execute(ctx, req.ID), "completed requests are skipped")
+
+	pseudonym := fmt.Sprintf("anon-%d", req.ID)
+	var login, first, last, email string
+	var phone sql.NullString
+	require.NoError(t, db.QueryRow("SELECT login, first_name, last_name, email, phone FROM customer_user").
+		Scan(&login, &first, &last, &email, &phone))
+	assert.Equal(t, pseudonym, login)
+	assert.Equal(t, "Anonymous", first)
+	assert.Equal(t, pseudonym, last)
+	assert.Equal(t, pseudonym+"@invalid", email)
+	assert.False(t, phone.Valid)
+
+	var customerUserID, title string
+	require.NoError(t, db.QueryRow("SELECT customer_user_id, title FROM ticket WHERE id = 1").Scan(&customerUserID, &title))
+	assert.Equal(t, pseudonym, customerUserID)
+	assert.Equal(t, "Printer", title, "titles are kept by default")
+
+	var from, body string
+	require.NoError(t, db.QueryRow("SELECT a_from, a_body FROM article_data_mime WHERE article_id = 10").Scan(&from, &body))
+	assert.Equal(t, pseudonym+" <"+pseudonym+"@invalid>", from)
+	assert.Empty(t, body)
+
+	var attachments int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM article_data_mime_attachment").Scan(&attachments))
+	assert.Zero(t, attachments)
+}
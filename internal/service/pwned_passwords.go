@@ -0,0 +1,74 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1" //nolint:gosec // G505 - the range API is keyed by SHA-1, nothing is protected by it
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultPwnedPasswordsURL is the HaveIBeenPwned password range API.
+const DefaultPwnedPasswordsURL = "https://api.pwnedpasswords.com/range/"
+
+// BreachChecker tells whether a password appears in known data breaches.
+type BreachChecker interface {
+	Breached(ctx context.Context, password string) (bool, error)
+}
+
+// PwnedPasswords checks passwords against the HaveIBeenPwned range API
+// using k-anonymity: only the first five hex digits of the password's SHA-1
+// leave the server, and the match is made locally against the returned
+// suffixes.
+type PwnedPasswords struct {
+	BaseURL string
+	Client  *http.Client
+}
+
+// NewPwnedPasswords creates a checker for the public HaveIBeenPwned API.
+func NewPwnedPasswords() *PwnedPasswords {
+	return &PwnedPasswords{
+		BaseURL: DefaultPwnedPasswordsURL,
+		Client:  &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// Breached reports whether password appears in the breach corpus.
+func (p *PwnedPasswords) Breached(ctx context.Context, password string) (bool, error) {
+	sum := sha1.Sum([]byte(password)) //nolint:gosec // G401 - see import
+	digest := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := digest[:5], digest[5:]
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.BaseURL+prefix, nil)
+	if err != nil {
+		return false, err
+	}
+	// Padding hides the real number of matching suffixes from observers.
+	req.Header.Set("Add-Padding", "true")
+	req.Header.Set("User-Agent", "GoatFlow")
+
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("pwned passwords lookup: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("pwned passwords lookup: unexpected status %d", resp.StatusCode)
+	}
+
+	// Each line is SUFFIX:COUNT; padding entries have a count of 0.
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		hash, count, ok := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if ok && strings.EqualFold(hash, suffix) && count != "0" {
+			return true, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return false, fmt.Errorf("pwned passwords lookup: %w", err)
+	}
+	return false, nil
+}
@@ -0,0 +1,362 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/repository"
+)
+
+// Errors returned by QueueManagementService. Validation errors wrap
+// ErrQueueInvalid.
+var (
+	ErrQueueNotFound       = errors.New("queue not found")
+	ErrQueueInvalid        = errors.New("invalid queue")
+	ErrQueueNameExists     = errors.New("queue name already exists")
+	ErrQueueParentNotFound = errors.New("parent queue does not exist")
+	ErrQueueCycle          = errors.New("a queue cannot be moved below itself or one of its sub-queues")
+	ErrQueueHasSubQueues   = errors.New("queue has valid sub-queues")
+)
+
+// QueueManagementService creates, changes and moves queues. Queues nest by
+// name: "Support::Hardware" is a sub-queue of "Support", so renaming or
+// moving a queue renames its whole subtree.
+type QueueManagementService struct {
+	repo *repository.QueueManagementRepository
+}
+
+// NewQueueManagementService creates a queue management service.
+func NewQueueManagementService(db *sql.DB) *QueueManagementService {
+	return &QueueManagementService{repo: repository.NewQueueManagementRepository(db)}
+}
+
+// DefaultQueueSettings returns the settings of a new queue before the
+// request's fields are applied. The IDs match the bootstrap lookup rows.
+func DefaultQueueSettings() *models.QueueSettings {
+	return &models.QueueSettings{
+		GroupID:         1,
+		SystemAddressID: 1,
+		SalutationID:    1,
+		SignatureID:     1,
+		FollowUpID:      1,
+		ValidID:         1,
+	}
+}
+
+// NormalizeQueueName trims each level of a nested queue name and rejects
+// empty levels, so " Support :: Hardware" becomes "Support::Hardware".
+func NormalizeQueueName(name string) (string, error) {
+	parts := strings.Split(name, models.QueueNameSeparator)
+	for i, p := range parts {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			if len(parts) == 1 {
+				return "", fmt.Errorf("%w: name is required", ErrQueueInvalid)
+			}
+			return "", fmt.Errorf("%w: name %q has an empty level", ErrQueueInvalid, name)
+		}
+		if strings.HasPrefix(p, ":") || strings.HasSuffix(p, ":") {
+			return "", fmt.Errorf("%w: name %q has a stray colon next to a level separator", ErrQueueInvalid, name)
+		}
+		parts[i] = p
+	}
+	normalized := strings.Join(parts, models.QueueNameSeparator)
+	if n := len([]rune(normalized)); n > models.MaxQueueNameLength {
+		return "", fmt.Errorf("%w: name is %d characters long, the maximum is %d", ErrQueueInvalid, n, models.MaxQueueNameLength)
+	}
+	return normalized, nil
+}
+
+// splitQueueName returns the parent part and the last level of a
+// normalized name. The parent is empty for a top-level queue.
+func splitQueueName(name string) (parent, short string) {
+	i := strings.LastIndex(name, models.QueueNameSeparator)
+	if i < 0 {
+		return "", name
+	}
+	return name[:i], name[i+len(models.QueueNameSeparator):]
+}
+
+// isBelow reports whether name lies in the subtree of ancestor.
+func isBelow(name, ancestor string) bool {
+	return strings.HasPrefix(strings.ToLower(name), strings.ToLower(ancestor+models.QueueNameSeparator))
+}
+
+// queueIndex is every queue by ID and by lower-cased name.
+type queueIndex struct {
+	nodes  []*models.QueueNode
+	byID   map[int]*models.QueueNode
+	byName map[string]*models.QueueNode
+}
+
+func (s *QueueManagementService) index(ctx context.Context) (*queueIndex, error) {
+	nodes, err := s.repo.ListNodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	idx := &queueIndex{
+		nodes:  nodes,
+		byID:   make(map[int]*models.QueueNode, len(nodes)),
+		byName: make(map[string]*models.QueueNode, len(nodes)),
+	}
+	for _, n := range nodes {
+		idx.byID[n.ID] = n
+		idx.byName[strings.ToLower(n.Name)] = n
+	}
+	return idx, nil
+}
+
+func (idx *queueIndex) lookup(name string) *models.QueueNode {
+	return idx.byName[strings.ToLower(name)]
+}
+
+// subtree returns the queues below name, without the queue itself.
+func (idx *queueIndex) subtree(name string) []*models.QueueNode {
+	var result []*models.QueueNode
+	for _, n := range idx.nodes {
+		if isBelow(n.Name, name) {
+			result = append(result, n)
+		}
+	}
+	return result
+}
+
+// Tree returns the top-level queues with their sub-queues nested in
+// Children. A queue whose parent does not exist is shown at the top level.
+func (s *QueueManagementService) Tree(ctx context.Context) ([]*models.QueueNode, error) {
+	idx, err := s.index(ctx)
+	if err != nil {
+		return nil, err
+	}
+	roots := []*models.QueueNode{}
+	for _, n := range idx.nodes {
+		parentName, short := splitQueueName(n.Name)
+		n.ShortName = short
+		n.Level = strings.Count(n.Name, models.QueueNameSeparator)
+		parent := idx.lookup(parentName)
+		if parentName == "" || parent == nil {
+			roots = append(roots, n)
+			continue
+		}
+		n.ParentID = parent.ID
+		parent.Children = append(parent.Children, n)
+	}
+	return roots, nil
+}
+
+// Get returns the settings of queue id.
+func (s *QueueManagementService) Get(ctx context.Context, id int) (*models.QueueSettings, error) {
+	settings, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if settings == nil {
+		return nil, ErrQueueNotFound
+	}
+	return settings, nil
+}
+
+// validateSettings checks the ranges and references of settings. The name
+// is checked by the callers, which know whether the queue moves.
+func (s *QueueManagementService) validateSettings(ctx context.Context, settings *models.QueueSettings) error {
+	if settings.UnlockTimeout < 0 {
+		return fmt.Errorf("%w: unlock_timeout must not be negative", ErrQueueInvalid)
+	}
+	if settings.FollowUpLock != 0 && settings.FollowUpLock != 1 {
+		return fmt.Errorf("%w: follow_up_lock must be 0 or 1", ErrQueueInvalid)
+	}
+	if settings.ValidID != 1 && settings.ValidID != 2 {
+		return fmt.Errorf("%w: valid_id must be 1 or 2", ErrQueueInvalid)
+	}
+	if settings.Comments != nil && len([]rune(*settings.Comments)) > 250 {
+		return fmt.Errorf("%w: comments must be at most 250 characters", ErrQueueInvalid)
+	}
+	for _, ref := range []struct {
+		field string
+		table repository.QueueReference
+		id    int
+	}{
+		{"group_id", repository.QueueRefGroup, settings.GroupID},
+		{"system_address_id", repository.QueueRefSystemAddress, settings.SystemAddressID},
+		{"salutation_id", repository.QueueRefSalutation, settings.SalutationID},
+		{"signature_id", repository.QueueRefSignature, settings.SignatureID},
+		{"follow_up_id", repository.QueueRefFollowUp, settings.FollowUpID},
+	} {
+		if ref.id <= 0 {
+			return fmt.Errorf("%w: %s is required", ErrQueueInvalid, ref.field)
+		}
+		ok, err := s.repo.ReferenceValid(ctx, ref.table, ref.id)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("%w: %s %d does not exist or is not valid", ErrQueueInvalid, ref.field, ref.id)
+		}
+	}
+	return nil
+}
+
+// placeName checks that name is free and that its parent exists. It
+// returns the name with the parent part spelled as stored. excludeID is
+// the queue being renamed, or 0.
+func placeName(idx *queueIndex, name string, excludeID int) (string, error) {
+	if other := idx.lookup(name); other != nil && other.ID != excludeID {
+		return "", fmt.Errorf("%w: %s", ErrQueueNameExists, other.Name)
+	}
+	parentName, short := splitQueueName(name)
+	if parentName == "" {
+		return name, nil
+	}
+	parent := idx.lookup(parentName)
+	if parent == nil {
+		return "", fmt.Errorf("%w: %s", ErrQueueParentNotFound, parentName)
+	}
+	return parent.Name + models.QueueNameSeparator + short, nil
+}
+
+// ValidateGroups checks that every ID is a valid group, as required for
+// group assignments.
+func (s *QueueManagementService) ValidateGroups(ctx context.Context, groupIDs []int) error {
+	for _, id := range groupIDs {
+		ok, err := s.repo.ReferenceValid(ctx, repository.QueueRefGroup, id)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("%w: group %d does not exist or is not valid", ErrQueueInvalid, id)
+		}
+	}
+	return nil
+}
+
+// Create validates settings and inserts the queue. The parent of a nested
+// name must already exist. settings.Name is updated to the stored name.
+func (s *QueueManagementService) Create(ctx context.Context, settings *models.QueueSettings, userID int) (int, error) {
+	name, err := NormalizeQueueName(settings.Name)
+	if err != nil {
+		return 0, err
+	}
+	idx, err := s.index(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if name, err = placeName(idx, name, 0); err != nil {
+		return 0, err
+	}
+	if err := s.validateSettings(ctx, settings); err != nil {
+		return 0, err
+	}
+	settings.Name = name
+	return s.repo.Create(ctx, settings, userID)
+}
+
+// Update validates settings and stores them for queue id. When the name
+// changes, the sub-queues are renamed with it in the same transaction, and
+// the queue may move below another parent. It returns the number of
+// sub-queues renamed; settings.Name is updated to the stored name.
+func (s *QueueManagementService) Update(ctx context.Context, id int, settings *models.QueueSettings, userID int) (int, error) {
+	name, err := NormalizeQueueName(settings.Name)
+	if err != nil {
+		return 0, err
+	}
+	idx, err := s.index(ctx)
+	if err != nil {
+		return 0, err
+	}
+	current := idx.byID[id]
+	if current == nil {
+		return 0, ErrQueueNotFound
+	}
+
+	renames := map[int]string{}
+	if name != current.Name {
+		if !strings.EqualFold(name, current.Name) && isBelow(name, current.Name) {
+			return 0, ErrQueueCycle
+		}
+		if name, err = placeName(idx, name, id); err != nil {
+			return 0, err
+		}
+		levels := strings.Count(current.Name, models.QueueNameSeparator) + 1
+		for _, sub := range idx.subtree(current.Name) {
+			rest := strings.Split(sub.Name, models.QueueNameSeparator)[levels:]
+			renamed := strings.Join(append([]string{name}, rest...), models.QueueNameSeparator)
+			if len([]rune(renamed)) > models.MaxQueueNameLength {
+				return 0, fmt.Errorf("%w: sub-queue name %q would be longer than %d characters",
+					ErrQueueInvalid, renamed, models.MaxQueueNameLength)
+			}
+			if other := idx.lookup(renamed); other != nil && other.ID != sub.ID && !isBelow(other.Name, current.Name) {
+				return 0, fmt.Errorf("%w: %s", ErrQueueNameExists, other.Name)
+			}
+			renames[sub.ID] = renamed
+		}
+	}
+	if settings.ValidID != 1 && current.ValidID == 1 {
+		for _, sub := range idx.subtree(current.Name) {
+			if sub.ValidID == 1 {
+				return 0, ErrQueueHasSubQueues
+			}
+		}
+	}
+	if err := s.validateSettings(ctx, settings); err != nil {
+		return 0, err
+	}
+
+	settings.Name = name
+	if err := s.repo.Update(ctx, id, settings, renames, userID); err != nil {
+		return 0, err
+	}
+	return len(renames), nil
+}
+
+// Move places queue id, with its sub-queues, below the queue parentID, or
+// at the top level when parentID is 0. The queue keeps its short name.
+func (s *QueueManagementService) Move(ctx context.Context, id, parentID, userID int) (*models.QueueSettings, int, error) {
+	settings, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, 0, err
+	}
+	_, short := splitQueueName(settings.Name)
+	name := short
+	if parentID != 0 {
+		parent, err := s.repo.Get(ctx, parentID)
+		if err != nil {
+			return nil, 0, err
+		}
+		if parent == nil {
+			return nil, 0, fmt.Errorf("%w: queue %d", ErrQueueParentNotFound, parentID)
+		}
+		if parentID == id || isBelow(parent.Name, settings.Name) {
+			return nil, 0, ErrQueueCycle
+		}
+		name = parent.Name + models.QueueNameSeparator + short
+	}
+	settings.Name = name
+	renamed, err := s.Update(ctx, id, settings, userID)
+	if err != nil {
+		return nil, 0, err
+	}
+	return settings, renamed, nil
+}
+
+// CheckDelete reports ErrQueueHasSubQueues when queue id has valid
+// sub-queues, which would be left without a valid parent.
+func (s *QueueManagementService) CheckDelete(ctx context.Context, id int) error {
+	idx, err := s.index(ctx)
+	if err != nil {
+		return err
+	}
+	current := idx.byID[id]
+	if current == nil {
+		return ErrQueueNotFound
+	}
+	for _, sub := range idx.subtree(current.Name) {
+		if sub.ValidID == 1 {
+			return ErrQueueHasSubQueues
+		}
+	}
+	return nil
+}
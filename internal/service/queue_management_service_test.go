@@ -0,0 +1,216 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/testutil"
+)
+
+func newQueueManagementTestService(t *testing.T) (*QueueManagementService, *sql.DB) {
+	t.Helper()
+	db := testutil.MigratedDB(t)
+	// The seeded queues 1-4 become Raw, Support and its sub-queues; group 3
+	// and signature 3 are invalid.
+	for _, stmt := range []string{
+		`INSERT INTO users (id, login, pw, first_name, last_name, valid_id, create_time, create_by, change_time, change_by) VALUES
+			(1, 'root@localhost', 'x', 'Admin', 'OTRS', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1),
+			(7, 'agent7', 'x', 'Agent', 'Seven', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+		`UPDATE queue SET name = 'Support' WHERE id = 2`,
+		`UPDATE queue SET name = 'Raw' WHERE id = 1`,
+		`UPDATE queue SET name = 'Support::Hardware' WHERE id = 3`,
+		`UPDATE queue SET name = 'Support::Hardware::Printers', valid_id = 2 WHERE id = 4`,
+		`UPDATE groups SET valid_id = 2 WHERE id = 3`,
+		`INSERT INTO signature (id, name, text, content_type, valid_id, create_time, create_by, change_time, change_by) VALUES
+			(2, 'Sales', 'Your Sales Team', 'text/plain', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1),
+			(3, 'Retired', 'Old', 'text/plain', 2, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+	} {
+		_, err := db.Exec(stmt)
+		require.NoError(t, err, stmt)
+	}
+	insertManagedTestQueue(t, db, "Sales")       // 5
+	insertManagedTestQueue(t, db, "Lost::Child") // 6
+	return NewQueueManagementService(db), db
+}
+
+func insertManagedTestQueue(t *testing.T, db *sql.DB, name string) {
+	t.Helper()
+	_, err := db.Exec(`INSERT INTO queue (name, group_id, system_address_id, salutation_id, signature_id, unlock_timeout,
+		follow_up_id, follow_up_lock, valid_id, create_time, create_by, change_time, change_by)
+		VALUES (?, 1, 1, 1, 1, 0, 1, 0, 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`, name)
+	require.NoError(t, err)
+}
+
+func queueNames(t *testing.T, db *sql.DB) map[int]string {
+	t.Helper()
+	rows, err := db.Query(`SELECT id, name FROM queue`)
+	require.NoError(t, err)
+	defer rows.Close()
+	names := map[int]string{}
+	for rows.Next() {
+		var id int
+		var name string
+		require.NoError(t, rows.Scan(&id, &name))
+		names[id] = name
+	}
+	require.NoError(t, rows.Err())
+	return names
+}
+
+func TestNormalizeQueueName(t *testing.T) {
+	name, err := NormalizeQueueName(" Support :: Hardware ")
+	require.NoError(t, err)
+	assert.Equal(t, "Support::Hardware", name)
+
+	for _, bad := range []string{"", "  ", "Support::", "::Support", "Support:: ::Hardware", "Support:::Hardware"} {
+		_, err := NormalizeQueueName(bad)
+		assert.ErrorIs(t, err, ErrQueueInvalid, bad)
+	}
+}
+
+func TestQueueManagementService_Tree(t *testing.T) {
+	svc, _ := newQueueManagementTestService(t)
+
+	roots, err := svc.Tree(context.Background())
+	require.NoError(t, err)
+	require.Len(t, roots, 4)
+	assert.Equal(t, "Lost::Child", roots[0].Name, "a missing parent leaves the queue at the top")
+	assert.Equal(t, "Child", roots[0].ShortName)
+
+	support := roots[3]
+	assert.Equal(t, "Support", support.Name)
+	require.Len(t, support.Children, 1)
+	hardware := support.Children[0]
+	assert.Equal(t, "Hardware", hardware.ShortName)
+	assert.Equal(t, 2, hardware.ParentID)
+	assert.Equal(t, 1, hardware.Level)
+	require.Len(t, hardware.Children, 1)
+	assert.Equal(t, 2, hardware.Children[0].Level)
+}
+
+func TestQueueManagementService_Create(t *testing.T) {
+	svc, db := newQueueManagementTestService(t)
+	ctx := context.Background()
+
+	settings := DefaultQueueSettings()
+	settings.Name = "support :: Software"
+	settings.UnlockTimeout = 30
+	settings.FollowUpID = 2
+	id, err := svc.Create(ctx, settings, 7)
+	require.NoError(t, err)
+	assert.Equal(t, "Support::Software", queueNames(t, db)[id], "the parent is spelled as stored")
+
+	for name, want := range map[string]error{
+		"Billing::Invoices": ErrQueueParentNotFound,
+		"SUPPORT::hardware": ErrQueueNameExists,
+		"Support::":         ErrQueueInvalid,
+	} {
+		settings := DefaultQueueSettings()
+		settings.Name = name
+		_, err := svc.Create(ctx, settings, 1)
+		assert.ErrorIs(t, err, want, name)
+	}
+
+	for _, mutate := range []func(*models.QueueSettings){
+		func(s *models.QueueSettings) { s.GroupID = 3 },
+		func(s *models.QueueSettings) { s.GroupID = 99 },
+		func(s *models.QueueSettings) { s.SystemAddressID = 0 },
+		func(s *models.QueueSettings) { s.SignatureID = 3 },
+		func(s *models.QueueSettings) { s.FollowUpID = 4 },
+		func(s *models.QueueSettings) { s.FollowUpLock = 2 },
+		func(s *models.QueueSettings) { s.UnlockTimeout = -1 },
+	} {
+		settings := DefaultQueueSettings()
+		settings.Name = "Billing"
+		mutate(settings)
+		_, err := svc.Create(ctx, settings, 1)
+		assert.ErrorIs(t, err, ErrQueueInvalid)
+	}
+}
+
+func TestQueueManagementService_RenameMovesSubtree(t *testing.T) {
+	svc, db := newQueueManagementTestService(t)
+	ctx := context.Background()
+
+	settings, err := svc.Get(ctx, 3)
+	require.NoError(t, err)
+	settings.Name = "Sales::Devices"
+	renamed, err := svc.Update(ctx, 3, settings, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 1, renamed)
+
+	names := queueNames(t, db)
+	assert.Equal(t, "Sales::Devices", names[3])
+	assert.Equal(t, "Sales::Devices::Printers", names[4])
+	assert.Equal(t, "Support", names[2])
+
+	settings, err = svc.Get(ctx, 2)
+	require.NoError(t, err)
+	settings.Name = "Sales::Devices::Support"
+	_, err = svc.Update(ctx, 2, settings, 1)
+	require.NoError(t, err, "Support has no sub-queues left")
+
+	settings, err = svc.Get(ctx, 5)
+	require.NoError(t, err)
+	settings.Name = "Sales::Devices::Printers::Sales"
+	_, err = svc.Update(ctx, 5, settings, 1)
+	assert.ErrorIs(t, err, ErrQueueCycle)
+
+	settings.Name = "Raw"
+	_, err = svc.Update(ctx, 5, settings, 1)
+	assert.ErrorIs(t, err, ErrQueueNameExists)
+
+	_, err = svc.Update(ctx, 99, settings, 1)
+	assert.ErrorIs(t, err, ErrQueueNotFound)
+}
+
+func TestQueueManagementService_Move(t *testing.T) {
+	svc, db := newQueueManagementTestService(t)
+	ctx := context.Background()
+
+	settings, renamed, err := svc.Move(ctx, 2, 5, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "Sales::Support", settings.Name)
+	assert.Equal(t, 2, renamed)
+	assert.Equal(t, "Sales::Support::Hardware::Printers", queueNames(t, db)[4])
+
+	settings, _, err = svc.Move(ctx, 3, 0, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "Hardware", settings.Name)
+	assert.Equal(t, "Hardware::Printers", queueNames(t, db)[4])
+
+	_, _, err = svc.Move(ctx, 3, 4, 1)
+	assert.ErrorIs(t, err, ErrQueueCycle)
+	_, _, err = svc.Move(ctx, 3, 3, 1)
+	assert.ErrorIs(t, err, ErrQueueCycle)
+	_, _, err = svc.Move(ctx, 3, 99, 1)
+	assert.ErrorIs(t, err, ErrQueueParentNotFound)
+
+	// Moving "Lost::Child" to the top level frees the "Child" name check.
+	insertManagedTestQueue(t, db, "Child")
+	_, _, err = svc.Move(ctx, 6, 0, 1)
+	assert.ErrorIs(t, err, ErrQueueNameExists)
+}
+
+func TestQueueManagementService_SubQueuesBlockDeactivation(t *testing.T) {
+	svc, _ := newQueueManagementTestService(t)
+	ctx := context.Background()
+
+	assert.ErrorIs(t, svc.CheckDelete(ctx, 2), ErrQueueHasSubQueues)
+	assert.NoError(t, svc.CheckDelete(ctx, 3), "the only sub-queue is already invalid")
+	assert.ErrorIs(t, svc.CheckDelete(ctx, 99), ErrQueueNotFound)
+
+	settings, err := svc.Get(ctx, 2)
+	require.NoError(t, err)
+	settings.ValidID = 2
+	_, err = svc.Update(ctx, 2, settings, 1)
+	assert.ErrorIs(t, err, ErrQueueHasSubQueues)
+
+	assert.NoError(t, svc.ValidateGroups(ctx, []int{1, 2}))
+	assert.ErrorIs(t, svc.ValidateGroups(ctx, []int{1, 3}), ErrQueueInvalid)
+}
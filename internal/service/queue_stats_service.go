@@ -0,0 +1,197 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/repository"
+)
+
+// ErrStatsWindow is returned for a statistics window that is not a
+// number of days between 1 and StatsMaxWindowDays, such as "30d".
+var ErrStatsWindow = errors.New("window must be a number of days such as 7d, between 1d and 365d")
+
+const (
+	// QueueStatsDefaultWindow is the window used when none is requested.
+	QueueStatsDefaultWindow = "7d"
+	// StatsMaxWindowDays caps the statistics window.
+	StatsMaxWindowDays = 365
+)
+
+// QueueStatsService aggregates the daily ticket statistics of queues and
+// summarises them over windows of days. Days run from midnight to midnight
+// in the service's location; only completed days are aggregated.
+type QueueStatsService struct {
+	repo     *repository.QueueStatsRepository
+	location *time.Location
+	now      func() time.Time
+}
+
+// NewQueueStatsService creates a queue statistics service working in UTC.
+func NewQueueStatsService(db *sql.DB) *QueueStatsService {
+	return &QueueStatsService{
+		repo:     repository.NewQueueStatsRepository(db),
+		location: time.UTC,
+		now:      time.Now,
+	}
+}
+
+// SetLocation sets the time zone days are counted in.
+func (s *QueueStatsService) SetLocation(loc *time.Location) {
+	if loc != nil {
+		s.location = loc
+	}
+}
+
+// Aggregate computes the statistics of the completed days of the last
+// backfillDays that have none yet and returns how many days it computed.
+// Open and pending counts are only known for yesterday, so days filled in
+// later have none. With retentionDays above zero, statistics older than
+// that are removed.
+func (s *QueueStatsService) Aggregate(ctx context.Context, backfillDays, retentionDays int) (int, error) {
+	if backfillDays < 1 {
+		backfillDays = 1
+	}
+	today := s.today()
+	existing, err := s.repo.Dates(ctx, statDate(today.AddDate(0, 0, -backfillDays)), statDate(today.AddDate(0, 0, -1)))
+	if err != nil {
+		return 0, err
+	}
+
+	computed := 0
+	for i := backfillDays; i >= 1; i-- {
+		start := today.AddDate(0, 0, -i)
+		date := statDate(start)
+		if existing[date.Format("2006-01-02")] {
+			continue
+		}
+		days, err := s.repo.Compute(ctx, start, start.AddDate(0, 0, 1), i == 1)
+		if err != nil {
+			return computed, fmt.Errorf("aggregate queue stats for %s: %w", date.Format("2006-01-02"), err)
+		}
+		if err := s.repo.SaveDay(ctx, date, days); err != nil {
+			return computed, err
+		}
+		computed++
+	}
+
+	if retentionDays > 0 {
+		if _, err := s.repo.DeleteBefore(ctx, statDate(today.AddDate(0, 0, -retentionDays))); err != nil {
+			return computed, err
+		}
+	}
+	return computed, nil
+}
+
+// Stats summarises the statistics of the completed days in a window ending
+// yesterday, such as "7d" for the last seven days. With queueIDs set, only
+// those queues are included.
+func (s *QueueStatsService) Stats(ctx context.Context, window string, queueIDs []int) (*models.QueueStatsReport, error) {
+	if window == "" {
+		window = QueueStatsDefaultWindow
+	}
+	n, err := parseStatsWindow(window)
+	if err != nil {
+		return nil, err
+	}
+	to := statDate(s.today().AddDate(0, 0, -1))
+	from := to.AddDate(0, 0, -(n - 1))
+
+	days, err := s.repo.Range(ctx, from, to, queueIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &models.QueueStatsReport{
+		Window: window,
+		From:   from.Format("2006-01-02"),
+		To:     to.Format("2006-01-02"),
+		Queues: make([]models.QueueStats, 0),
+	}
+	var asOf time.Time
+	var sums struct {
+		firstCount, resCount int
+		firstSecs, resSecs   int64
+	}
+	var current *models.QueueStats
+	flush := func() {
+		if current == nil {
+			return
+		}
+		current.AvgFirstResponseSeconds = averageSeconds(sums.firstSecs, sums.firstCount)
+		current.AvgResolutionSeconds = averageSeconds(sums.resSecs, sums.resCount)
+		report.Queues = append(report.Queues, *current)
+		sums.firstCount, sums.resCount, sums.firstSecs, sums.resSecs = 0, 0, 0, 0
+	}
+	for _, d := range days {
+		if current == nil || current.QueueID != d.QueueID {
+			flush()
+			current = &models.QueueStats{QueueID: d.QueueID, QueueName: d.QueueName, Trend: []models.QueueStatsPoint{}}
+		}
+		// Days are in order, so the last snapshot is the latest
+		if d.OpenCount != nil {
+			current.Open = *d.OpenCount
+		}
+		if d.PendingCount != nil {
+			current.Pending = *d.PendingCount
+		}
+		current.Created += d.CreatedCount
+		current.Closed += d.ClosedCount
+		sums.firstCount += d.FirstResponseCount
+		sums.firstSecs += d.FirstResponseSeconds
+		sums.resCount += d.ResolutionCount
+		sums.resSecs += d.ResolutionSeconds
+		current.Trend = append(current.Trend, models.QueueStatsPoint{
+			Date:                    d.Date.Format("2006-01-02"),
+			Open:                    d.OpenCount,
+			Pending:                 d.PendingCount,
+			Created:                 d.CreatedCount,
+			Closed:                  d.ClosedCount,
+			AvgFirstResponseSeconds: averageSeconds(d.FirstResponseSeconds, d.FirstResponseCount),
+			AvgResolutionSeconds:    averageSeconds(d.ResolutionSeconds, d.ResolutionCount),
+		})
+		if d.Date.After(asOf) {
+			asOf = d.Date
+		}
+	}
+	flush()
+	if !asOf.IsZero() {
+		report.AsOf = asOf.Format("2006-01-02")
+	}
+	return report, nil
+}
+
+// today returns the start of the current day in the service's location.
+func (s *QueueStatsService) today() time.Time {
+	now := s.now().In(s.location)
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, s.location)
+}
+
+// statDate returns the calendar day of t as stored in the statistics table.
+func statDate(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// parseStatsWindow returns the number of days of a window such as
+// "30d".
+func parseStatsWindow(window string) (int, error) {
+	n, err := strconv.Atoi(strings.TrimSuffix(strings.ToLower(strings.TrimSpace(window)), "d"))
+	if err != nil || !strings.HasSuffix(strings.ToLower(window), "d") || n < 1 || n > StatsMaxWindowDays {
+		return 0, ErrStatsWindow
+	}
+	return n, nil
+}
+
+func averageSeconds(sum int64, count int) *float64 {
+	if count == 0 {
+		return nil
+	}
+	avg := float64(sum) / float64(count)
+	return &avg
+}
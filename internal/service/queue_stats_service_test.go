@@ -0,0 +1,134 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/testutil"
+)
+
+func TestParseStatsWindow(t *testing.T) {
+	for window, want := range map[string]int{"1d": 1, "7d": 7, "30D": 30, "365d": 365} {
+		n, err := parseStatsWindow(window)
+		require.NoError(t, err, window)
+		assert.Equal(t, want, n, window)
+	}
+	for _, window := range []string{"0d", "366d", "7", "d", "1w", "-3d", "seven"} {
+		_, err := parseStatsWindow(window)
+		assert.ErrorIs(t, err, ErrStatsWindow, window)
+	}
+}
+
+func TestQueueStatsService_AggregateAndStats(t *testing.T) {
+	db := testutil.UseMigratedDB(t)
+	for _, stmt := range []string{
+		// Queues 1 to 4 and the standard states come with the migrations
+		`INSERT INTO queue (id, name, group_id, system_address_id, salutation_id, signature_id, follow_up_id, follow_up_lock,
+			valid_id, create_time, create_by, change_time, change_by)
+			VALUES (9, 'Retired', 1, 1, 1, 1, 1, 0, 2, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+	} {
+		_, err := db.Exec(stmt)
+		require.NoError(t, err, stmt)
+	}
+
+	now := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	day := func(daysAgo, hour int) time.Time {
+		return time.Date(2026, 10, 16-daysAgo, hour, 0, 0, 0, time.UTC)
+	}
+	addTicket := func(id, queueID, stateID int, created, changed time.Time) {
+		_, err := db.Exec(`INSERT INTO ticket (id, tn, title, queue_id, ticket_lock_id, user_id, responsible_user_id,
+			ticket_priority_id, ticket_state_id, timeout, until_time, escalation_time, escalation_update_time,
+			escalation_response_time, escalation_solution_time, archive_flag, create_time, create_by, change_time, change_by)
+			VALUES (?, ?, 'Printer', ?, 1, 1, 1, 3, ?, 0, 0, 0, 0, 0, 0, 0, ?, 1, ?, 1)`,
+			id, id, queueID, stateID, created, changed)
+		require.NoError(t, err)
+	}
+	addArticle := func(id, ticketID, senderTypeID, visible int, created time.Time) {
+		_, err := db.Exec(`INSERT INTO article (id, ticket_id, article_sender_type_id, communication_channel_id,
+			is_visible_for_customer, create_time, create_by, change_time, change_by)
+			VALUES (?, ?, ?, 1, ?, ?, 1, ?, 1)`, id, ticketID, senderTypeID, visible, created, created)
+		require.NoError(t, err)
+	}
+
+	// Two days ago: ticket 1 created in Raw and answered two hours later
+	addTicket(1, 2, 4, day(2, 8), day(1, 14))
+	addArticle(1, 1, 3, 1, day(2, 8))
+	addArticle(2, 1, 1, 0, day(2, 9))
+	addArticle(3, 1, 1, 1, day(2, 10))
+	addArticle(4, 1, 1, 1, day(1, 12))
+	// Yesterday: ticket 1 closed after 30 hours, ticket 2 created and answered
+	// an hour later, ticket 3 pending, ticket 4 in a retired queue
+	addTicket(2, 2, 2, day(1, 9), day(1, 9))
+	addArticle(5, 2, 1, 1, day(1, 10))
+	addTicket(3, 1, 3, day(1, 11), day(1, 11))
+	addTicket(4, 9, 1, day(1, 11), day(1, 11))
+	// Today is not aggregated yet but counts as open in the snapshot
+	addTicket(5, 2, 1, day(0, 8), day(0, 8))
+
+	svc := NewQueueStatsService(db)
+	svc.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	computed, err := svc.Aggregate(ctx, 3, 30)
+	require.NoError(t, err)
+	assert.Equal(t, 3, computed)
+	computed, err = svc.Aggregate(ctx, 3, 30)
+	require.NoError(t, err)
+	assert.Zero(t, computed, "aggregated days are kept")
+
+	report, err := svc.Stats(ctx, "", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "7d", report.Window)
+	assert.Equal(t, "2026-10-09", report.From)
+	assert.Equal(t, "2026-10-15", report.To)
+	assert.Equal(t, "2026-10-15", report.AsOf)
+	require.Len(t, report.Queues, 4, "retired queues are left out")
+
+	postmaster, raw := report.Queues[0], report.Queues[1]
+	assert.Equal(t, "Postmaster", postmaster.QueueName)
+	assert.Equal(t, 0, postmaster.Open)
+	assert.Equal(t, 1, postmaster.Pending)
+	assert.Equal(t, 1, postmaster.Created)
+	assert.Nil(t, postmaster.AvgFirstResponseSeconds)
+
+	assert.Equal(t, "Raw", raw.QueueName)
+	assert.Equal(t, 2, raw.Open, "the snapshot is taken when the job runs")
+	assert.Equal(t, 2, raw.Created)
+	assert.Equal(t, 1, raw.Closed)
+	require.NotNil(t, raw.AvgFirstResponseSeconds)
+	assert.Equal(t, 5400.0, *raw.AvgFirstResponseSeconds)
+	require.NotNil(t, raw.AvgResolutionSeconds)
+	assert.Equal(t, 108000.0, *raw.AvgResolutionSeconds)
+
+	require.Len(t, raw.Trend, 3)
+	assert.Equal(t, "2026-10-13", raw.Trend[0].Date)
+	assert.Nil(t, raw.Trend[1].Open, "backfilled days have no snapshot")
+	assert.Equal(t, 1, raw.Trend[1].Created)
+	assert.Equal(t, 7200.0, *raw.Trend[1].AvgFirstResponseSeconds)
+	require.NotNil(t, raw.Trend[2].Open)
+	assert.Equal(t, 2, *raw.Trend[2].Open)
+	assert.Equal(t, 3600.0, *raw.Trend[2].AvgFirstResponseSeconds)
+
+	report, err = svc.Stats(ctx, "1d", []int{1})
+	require.NoError(t, err)
+	require.Len(t, report.Queues, 1)
+	assert.Equal(t, 1, report.Queues[0].QueueID)
+	report, err = svc.Stats(ctx, "1d", []int{})
+	require.NoError(t, err)
+	assert.Empty(t, report.Queues)
+
+	_, err = svc.Stats(ctx, "1y", nil)
+	assert.ErrorIs(t, err, ErrStatsWindow)
+
+	svc.now = func() time.Time { return now.AddDate(0, 0, 30) }
+	_, err = svc.Aggregate(ctx, 1, 30)
+	require.NoError(t, err)
+	report, err = svc.Stats(ctx, "365d", nil)
+	require.NoError(t, err)
+	require.Len(t, report.Queues, 4)
+	assert.Len(t, report.Queues[0].Trend, 1, "statistics past their retention are removed")
+}
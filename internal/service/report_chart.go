@@ -0,0 +1,196 @@
+package service
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+	"sort"
+	"strconv"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+
+	"github.com/goatkit/goatflow/internal/models"
+)
+
+const (
+	reportChartHeight    = 400
+	reportChartMinWidth  = 640
+	reportChartMaxWidth  = 2400
+	reportChartSlotWidth = 48 // Horizontal space per category
+	reportChartMarginL   = 64
+	reportChartMarginR   = 16
+	reportChartMarginT   = 48
+	reportChartMarginB   = 56
+	reportChartGridLines = 5
+)
+
+var (
+	reportChartText = color.RGBA{0x33, 0x33, 0x33, 0xff}
+	reportChartGrid = color.RGBA{0xdd, 0xdd, 0xdd, 0xff}
+	reportChartAxis = color.RGBA{0x88, 0x88, 0x88, 0xff}
+	// reportChartPalette colours the series; it repeats past eight series.
+	reportChartPalette = []color.RGBA{
+		{0x2b, 0x6c, 0xb0, 0xff}, {0xdd, 0x6b, 0x20, 0xff}, {0x2f, 0x85, 0x5a, 0xff}, {0xc5, 0x30, 0x30, 0xff},
+		{0x6b, 0x46, 0xc1, 0xff}, {0x97, 0x5a, 0x16, 0xff}, {0xd5, 0x3f, 0x8c, 0xff}, {0x4a, 0x55, 0x68, 0xff},
+	}
+)
+
+// RenderReportPNG renders a report result as a bar chart. Time buckets form
+// the x axis with one bar per group; without a time bucket each group is a
+// category of its own.
+func RenderReportPNG(result *models.ReportResult) ([]byte, error) {
+	categories, series, values := reportChartData(result)
+
+	width := reportChartMarginL + reportChartMarginR + len(categories)*reportChartSlotWidth
+	if width < reportChartMinWidth {
+		width = reportChartMinWidth
+	}
+	if width > reportChartMaxWidth {
+		width = reportChartMaxWidth
+	}
+	img := image.NewRGBA(image.Rect(0, 0, width, reportChartHeight))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+
+	plot := image.Rect(reportChartMarginL, reportChartMarginT, width-reportChartMarginR, reportChartHeight-reportChartMarginB)
+	chartText(img, reportChartMarginL, 20, result.Name+" - "+reportMetricLabels[result.Metric], reportChartText)
+
+	maxValue := 0.0
+	for _, v := range values {
+		maxValue = math.Max(maxValue, v)
+	}
+	top := chartNiceCeil(maxValue)
+
+	// Horizontal grid with value labels
+	for i := 0; i <= reportChartGridLines; i++ {
+		y := plot.Max.Y - i*plot.Dy()/reportChartGridLines
+		fillRect(img, image.Rect(plot.Min.X, y, plot.Max.X, y+1), reportChartGrid)
+		label := strconv.FormatFloat(top*float64(i)/reportChartGridLines, 'f', -1, 64)
+		chartText(img, plot.Min.X-8-len(label)*7, y+4, label, reportChartText)
+	}
+	fillRect(img, image.Rect(plot.Min.X, plot.Min.Y, plot.Min.X+1, plot.Max.Y+1), reportChartAxis)
+	fillRect(img, image.Rect(plot.Min.X, plot.Max.Y, plot.Max.X, plot.Max.Y+1), reportChartAxis)
+
+	if len(categories) == 0 {
+		chartText(img, plot.Min.X+plot.Dx()/2-42, plot.Min.Y+plot.Dy()/2, "No data", reportChartText)
+		return encodeChart(img)
+	}
+
+	slot := plot.Dx() / len(categories)
+	barWidth := (slot * 8 / 10) / len(series)
+	if barWidth < 1 {
+		barWidth = 1
+	}
+	for ci, category := range categories {
+		x0 := plot.Min.X + ci*slot + slot/10
+		for si, s := range series {
+			v := values[category+"\x00"+s]
+			h := int(math.Round(v / top * float64(plot.Dy())))
+			x := x0 + si*barWidth
+			fillRect(img, image.Rect(x, plot.Max.Y-h, x+barWidth-1, plot.Max.Y), reportChartPalette[si%len(reportChartPalette)])
+		}
+		chartText(img, plot.Min.X+ci*slot+2, plot.Max.Y+16, chartTruncate(category, (slot-4)/7), reportChartText)
+	}
+
+	// Legend, only when there is more than one series
+	if len(series) > 1 {
+		x := reportChartMarginL
+		y := reportChartHeight - 18
+		for si, s := range series {
+			if x > width-80 {
+				break
+			}
+			fillRect(img, image.Rect(x, y-9, x+10, y+1), reportChartPalette[si%len(reportChartPalette)])
+			label := chartTruncate(s, 16)
+			chartText(img, x+14, y, label, reportChartText)
+			x += 14 + len(label)*7 + 16
+		}
+	}
+
+	return encodeChart(img)
+}
+
+// reportChartData splits result rows into x categories, series and values
+// keyed by category and series.
+func reportChartData(result *models.ReportResult) ([]string, []string, map[string]float64) {
+	byTime := result.TimeBucket != models.ReportBucketNone
+	catSeen := make(map[string]bool)
+	seriesSeen := make(map[string]bool)
+	var categories, series []string
+	values := make(map[string]float64, len(result.Rows))
+
+	for _, row := range result.Rows {
+		category, s := row.Group, ""
+		if byTime {
+			category, s = row.Bucket, row.Group
+		}
+		if !catSeen[category] {
+			catSeen[category] = true
+			categories = append(categories, category)
+		}
+		if !seriesSeen[s] {
+			seriesSeen[s] = true
+			series = append(series, s)
+		}
+		values[category+"\x00"+s] = row.Value
+	}
+	sort.Strings(series)
+	if len(series) == 0 {
+		series = []string{""}
+	}
+	return categories, series, values
+}
+
+// chartNiceCeil rounds v up to 1, 2 or 5 times a power of ten.
+func chartNiceCeil(v float64) float64 {
+	if v <= 0 {
+		return 1
+	}
+	exp := math.Pow(10, math.Floor(math.Log10(v)))
+	for _, m := range []float64{1, 2, 5, 10} {
+		if v <= m*exp {
+			return m * exp
+		}
+	}
+	return 10 * exp
+}
+
+func chartTruncate(s string, max int) string {
+	r := []rune(s)
+	if max < 1 {
+		return ""
+	}
+	if len(r) <= max {
+		return s
+	}
+	if max == 1 {
+		return string(r[:1])
+	}
+	return string(r[:max-1]) + "~"
+}
+
+func chartText(img draw.Image, x, y int, text string, c color.Color) {
+	d := &font.Drawer{
+		Dst:  img,
+		Src:  image.NewUniform(c),
+		Face: basicfont.Face7x13,
+		Dot:  fixed.P(x, y),
+	}
+	d.DrawString(text)
+}
+
+func fillRect(img draw.Image, r image.Rectangle, c color.Color) {
+	draw.Draw(img, r, image.NewUniform(c), image.Point{}, draw.Src)
+}
+
+func encodeChart(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,477 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"html"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+
+	"github.com/goatkit/goatflow/internal/mailqueue"
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/repository"
+)
+
+// Errors returned by ReportService.
+var (
+	ErrReportNotFound           = errors.New("report not found")
+	ErrReportNameRequired       = errors.New("report name is required")
+	ErrReportNameExists         = errors.New("a report with this name already exists")
+	ErrReportInvalidMetric      = errors.New("metric must be one of tickets_created, tickets_closed, avg_resolution_hours, survey_responses, avg_satisfaction")
+	ErrReportInvalidGrouping    = errors.New("group_by must be one of none, queue, state, priority, owner, customer, type")
+	ErrReportInvalidBucket      = errors.New("time_bucket must be one of none, day, week, month")
+	ErrReportInvalidFormat      = errors.New("format must be one of table, csv, png")
+	ErrReportInvalidPeriod      = errors.New("period_days must be between 1 and 366")
+	ErrReportInvalidSchedule    = errors.New("schedule must be a five-field cron expression")
+	ErrReportRecipientsRequired = errors.New("scheduled reports need at least one recipient group")
+)
+
+const (
+	// reportDefaultPeriodDays is the period covered when a report sets none.
+	reportDefaultPeriodDays = 30
+	// reportMaxPeriodDays caps how far back a report may look.
+	reportMaxPeriodDays = 366
+)
+
+// reportCronParser parses report schedules: standard five-field cron
+// expressions plus descriptors such as @daily and @weekly.
+var reportCronParser = cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+// reportMetricLabels are the column headings used for each metric.
+var reportMetricLabels = map[models.ReportMetric]string{
+	models.ReportMetricTicketsCreated:     "Tickets created",
+	models.ReportMetricTicketsClosed:      "Tickets closed",
+	models.ReportMetricAvgResolutionHours: "Avg. resolution (hours)",
+	models.ReportMetricSurveyResponses:    "Survey responses",
+	models.ReportMetricAvgSatisfaction:    "Avg. satisfaction (1-5)",
+}
+
+// ReportService handles report definitions, execution and delivery.
+type ReportService struct {
+	repo *repository.ReportRepository
+}
+
+// NewReportService creates a new report service.
+func NewReportService(repo *repository.ReportRepository) *ReportService {
+	return &ReportService{repo: repo}
+}
+
+// List returns all valid report definitions.
+func (s *ReportService) List(ctx context.Context) ([]models.ReportDefinition, error) {
+	return s.repo.List(ctx)
+}
+
+// ListScheduled returns the valid report definitions that have a schedule.
+func (s *ReportService) ListScheduled(ctx context.Context) ([]models.ReportDefinition, error) {
+	return s.repo.ListScheduled(ctx)
+}
+
+// Get returns a report definition.
+func (s *ReportService) Get(ctx context.Context, id int) (*models.ReportDefinition, error) {
+	def, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if def == nil {
+		return nil, ErrReportNotFound
+	}
+	return def, nil
+}
+
+// Create validates and stores a new report definition.
+func (s *ReportService) Create(ctx context.Context, def *models.ReportDefinition) error {
+	if err := s.validate(ctx, def); err != nil {
+		return err
+	}
+	id, err := s.repo.Create(ctx, def)
+	if err != nil {
+		return err
+	}
+	def.ID = id
+	return nil
+}
+
+// Update validates and stores changes to an existing report definition.
+func (s *ReportService) Update(ctx context.Context, def *models.ReportDefinition, userID int) error {
+	if _, err := s.Get(ctx, def.ID); err != nil {
+		return err
+	}
+	if err := s.validate(ctx, def); err != nil {
+		return err
+	}
+	return s.repo.Update(ctx, def, userID)
+}
+
+// Delete invalidates a report definition.
+func (s *ReportService) Delete(ctx context.Context, id int, userID int) error {
+	if _, err := s.Get(ctx, id); err != nil {
+		return err
+	}
+	return s.repo.Invalidate(ctx, id, userID)
+}
+
+// Run computes a report over the period ending at now.
+func (s *ReportService) Run(ctx context.Context, def *models.ReportDefinition, now time.Time) (*models.ReportResult, error) {
+	from, to := reportPeriod(def, now)
+	tickets, err := s.repo.Tickets(ctx, def.Metric, def.Filters, from, to)
+	if err != nil {
+		return nil, err
+	}
+	result := AggregateReport(def, tickets, from, to)
+	return &result, nil
+}
+
+// SendScheduled runs a report and queues it by email to the agents in its
+// recipient groups, then records the delivery time. It returns the number
+// of emails queued.
+func (s *ReportService) SendScheduled(ctx context.Context, queue *mailqueue.MailQueueRepository, def *models.ReportDefinition, now time.Time, from string) (int, error) {
+	recipients, err := s.repo.RecipientEmails(ctx, def.RecipientGroupIDs)
+	if err != nil {
+		return 0, err
+	}
+	queued := 0
+	if len(recipients) > 0 {
+		result, err := s.Run(ctx, def, now)
+		if err != nil {
+			return 0, err
+		}
+		messages, err := buildReportEmails(result, def.ScheduleFormat, from, recipients)
+		if err != nil {
+			return 0, err
+		}
+		for i, msg := range messages {
+			sender := from
+			item := &mailqueue.MailQueueItem{
+				Sender:     &sender,
+				Recipient:  recipients[i],
+				RawMessage: msg,
+				CreateTime: now,
+			}
+			if err := queue.Insert(ctx, item); err != nil {
+				return queued, fmt.Errorf("queue report %d for %s: %w", def.ID, recipients[i], err)
+			}
+			queued++
+		}
+	}
+	return queued, s.repo.MarkSent(ctx, def.ID, now)
+}
+
+// DueReports returns the scheduled reports whose next run is at or before now.
+// A report that was never sent is scheduled from its creation time.
+func DueReports(defs []models.ReportDefinition, now time.Time) []models.ReportDefinition {
+	due := make([]models.ReportDefinition, 0)
+	for _, def := range defs {
+		next, err := ReportNextRun(def, now)
+		if err != nil || next.IsZero() {
+			continue
+		}
+		if !next.After(now) {
+			due = append(due, def)
+		}
+	}
+	return due
+}
+
+// ReportNextRun returns when a scheduled report is next due, counted from
+// its last delivery. It returns the zero time for unscheduled reports.
+func ReportNextRun(def models.ReportDefinition, now time.Time) (time.Time, error) {
+	if def.Schedule == "" {
+		return time.Time{}, nil
+	}
+	sched, err := reportCronParser.Parse(def.Schedule)
+	if err != nil {
+		return time.Time{}, ErrReportInvalidSchedule
+	}
+	last := def.CreateTime
+	if def.LastSentTime != nil {
+		last = *def.LastSentTime
+	}
+	if last.IsZero() {
+		last = now
+	}
+	return sched.Next(last), nil
+}
+
+func (s *ReportService) validate(ctx context.Context, def *models.ReportDefinition) error {
+	def.Name = strings.TrimSpace(def.Name)
+	def.Schedule = strings.TrimSpace(def.Schedule)
+	if def.Name == "" {
+		return ErrReportNameRequired
+	}
+	if err := ValidateReportDefinition(def); err != nil {
+		return err
+	}
+	exists, err := s.repo.NameExists(ctx, def.Name, def.ID)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return ErrReportNameExists
+	}
+	return nil
+}
+
+// ValidateReportDefinition checks the metric, grouping, filters and schedule
+// of a definition, filling in defaults for omitted fields.
+func ValidateReportDefinition(def *models.ReportDefinition) error {
+	if def.GroupBy == "" {
+		def.GroupBy = models.ReportGroupNone
+	}
+	if def.TimeBucket == "" {
+		def.TimeBucket = models.ReportBucketNone
+	}
+	if !def.Metric.IsValid() {
+		return ErrReportInvalidMetric
+	}
+	if !def.GroupBy.IsValid() {
+		return ErrReportInvalidGrouping
+	}
+	if !def.TimeBucket.IsValid() {
+		return ErrReportInvalidBucket
+	}
+	if def.Filters.PeriodDays < 0 || def.Filters.PeriodDays > reportMaxPeriodDays {
+		return ErrReportInvalidPeriod
+	}
+
+	if def.Schedule == "" {
+		def.ScheduleFormat = ""
+		def.RecipientGroupIDs = nil
+		return nil
+	}
+	if _, err := reportCronParser.Parse(def.Schedule); err != nil {
+		return ErrReportInvalidSchedule
+	}
+	if def.ScheduleFormat == "" {
+		def.ScheduleFormat = models.ReportFormatTable
+	}
+	if !def.ScheduleFormat.IsValid() {
+		return ErrReportInvalidFormat
+	}
+	if len(def.RecipientGroupIDs) == 0 {
+		return ErrReportRecipientsRequired
+	}
+	return nil
+}
+
+// AggregateReport computes report rows from ticket data. Rows are ordered by
+// time bucket, then group; buckets without tickets are omitted.
+func AggregateReport(def *models.ReportDefinition, tickets []models.ReportTicket, from, to time.Time) models.ReportResult {
+	type key struct{ bucket, group string }
+	type agg struct {
+		count  int
+		hours  float64
+		rating float64
+	}
+	totals := make(map[key]*agg)
+
+	for _, t := range tickets {
+		at := t.ChangeTime
+		if def.Metric == models.ReportMetricTicketsCreated {
+			at = t.CreateTime
+		}
+		k := key{bucket: reportBucketKey(at, def.TimeBucket), group: reportGroupLabel(t, def.GroupBy)}
+		a := totals[k]
+		if a == nil {
+			a = &agg{}
+			totals[k] = a
+		}
+		a.count++
+		a.hours += t.ChangeTime.Sub(t.CreateTime).Hours()
+		a.rating += t.Rating
+	}
+
+	rows := make([]models.ReportRow, 0, len(totals))
+	for k, a := range totals {
+		row := models.ReportRow{Bucket: k.bucket, Group: k.group, Count: a.count, Value: float64(a.count)}
+		switch def.Metric {
+		case models.ReportMetricAvgResolutionHours:
+			row.Value = math.Round(a.hours/float64(a.count)*100) / 100
+		case models.ReportMetricAvgSatisfaction:
+			row.Value = math.Round(a.rating/float64(a.count)*100) / 100
+		}
+		rows = append(rows, row)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].Bucket != rows[j].Bucket {
+			return rows[i].Bucket < rows[j].Bucket
+		}
+		return rows[i].Group < rows[j].Group
+	})
+
+	return models.ReportResult{
+		ReportID:   def.ID,
+		Name:       def.Name,
+		Metric:     def.Metric,
+		GroupBy:    def.GroupBy,
+		TimeBucket: def.TimeBucket,
+		From:       from,
+		To:         to,
+		Rows:       rows,
+	}
+}
+
+// RenderReportCSV renders a report result as CSV with a header row.
+func RenderReportCSV(result *models.ReportResult) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := w.Write(reportColumns(result)); err != nil {
+		return nil, err
+	}
+	for _, row := range result.Rows {
+		if err := w.Write(reportRecord(result, row)); err != nil {
+			return nil, err
+		}
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// RenderReportHTML renders a report result as an HTML table for email bodies.
+func RenderReportHTML(result *models.ReportResult) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "<h2>%s</h2>\n", html.EscapeString(result.Name))
+	fmt.Fprintf(&b, "<p>%s &ndash; %s</p>\n",
+		result.From.Format("2006-01-02 15:04"), result.To.Format("2006-01-02 15:04"))
+	b.WriteString(`<table border="1" cellpadding="4" cellspacing="0">` + "\n<tr>")
+	for _, col := range reportColumns(result) {
+		fmt.Fprintf(&b, "<th>%s</th>", html.EscapeString(col))
+	}
+	b.WriteString("</tr>\n")
+	for _, row := range result.Rows {
+		b.WriteString("<tr>")
+		for _, cell := range reportRecord(result, row) {
+			fmt.Fprintf(&b, "<td>%s</td>", html.EscapeString(cell))
+		}
+		b.WriteString("</tr>\n")
+	}
+	b.WriteString("</table>\n")
+	if len(result.Rows) == 0 {
+		b.WriteString("<p>No tickets matched this report.</p>\n")
+	}
+	return b.String()
+}
+
+// reportColumns returns the headings for a result's rows.
+func reportColumns(result *models.ReportResult) []string {
+	var cols []string
+	if result.TimeBucket != models.ReportBucketNone {
+		cols = append(cols, "Period")
+	}
+	if result.GroupBy != models.ReportGroupNone {
+		cols = append(cols, strings.ToUpper(string(result.GroupBy[:1]))+string(result.GroupBy[1:]))
+	}
+	cols = append(cols, reportMetricLabels[result.Metric])
+	switch result.Metric {
+	case models.ReportMetricAvgResolutionHours:
+		cols = append(cols, "Tickets")
+	case models.ReportMetricAvgSatisfaction:
+		cols = append(cols, "Responses")
+	}
+	return cols
+}
+
+// reportRecord returns the cells of a row in reportColumns order.
+func reportRecord(result *models.ReportResult, row models.ReportRow) []string {
+	var rec []string
+	if result.TimeBucket != models.ReportBucketNone {
+		rec = append(rec, row.Bucket)
+	}
+	if result.GroupBy != models.ReportGroupNone {
+		rec = append(rec, row.Group)
+	}
+	rec = append(rec, strconv.FormatFloat(row.Value, 'f', -1, 64))
+	if result.Metric == models.ReportMetricAvgResolutionHours || result.Metric == models.ReportMetricAvgSatisfaction {
+		rec = append(rec, strconv.Itoa(row.Count))
+	}
+	return rec
+}
+
+// reportPeriod returns the [from, to) range a run at now covers.
+func reportPeriod(def *models.ReportDefinition, now time.Time) (time.Time, time.Time) {
+	days := def.Filters.PeriodDays
+	if days <= 0 {
+		days = reportDefaultPeriodDays
+	}
+	return now.AddDate(0, 0, -days), now
+}
+
+// reportBucketKey returns the sortable label of the bucket containing t.
+func reportBucketKey(t time.Time, bucket models.ReportBucket) string {
+	switch bucket {
+	case models.ReportBucketDay:
+		return t.Format("2006-01-02")
+	case models.ReportBucketWeek:
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week)
+	case models.ReportBucketMonth:
+		return t.Format("2006-01")
+	}
+	return ""
+}
+
+// reportGroupLabel returns the value a ticket is grouped under.
+func reportGroupLabel(t models.ReportTicket, groupBy models.ReportGrouping) string {
+	var label string
+	switch groupBy {
+	case models.ReportGroupNone:
+		return ""
+	case models.ReportGroupQueue:
+		label = t.Queue
+	case models.ReportGroupState:
+		label = t.State
+	case models.ReportGroupPriority:
+		label = t.Priority
+	case models.ReportGroupOwner:
+		label = t.Owner
+	case models.ReportGroupCustomer:
+		label = t.Customer
+	case models.ReportGroupType:
+		label = t.Type
+	}
+	if label == "" {
+		return "(none)"
+	}
+	return label
+}
+
+// buildReportEmails renders a result in the scheduled format and returns one
+// message per recipient.
+func buildReportEmails(result *models.ReportResult, format models.ReportFormat, from string, recipients []string) ([][]byte, error) {
+	subject := "Report: " + result.Name
+	body := RenderReportHTML(result)
+	stamp := result.To.Format("20060102")
+
+	var attachment *mailqueue.Attachment
+	switch format {
+	case models.ReportFormatCSV:
+		data, err := RenderReportCSV(result)
+		if err != nil {
+			return nil, err
+		}
+		attachment = &mailqueue.Attachment{Filename: "report-" + stamp + ".csv", ContentType: "text/csv", Data: data}
+	case models.ReportFormatPNG:
+		data, err := RenderReportPNG(result)
+		if err != nil {
+			return nil, err
+		}
+		attachment = &mailqueue.Attachment{Filename: "report-" + stamp + ".png", ContentType: "image/png", Data: data}
+	}
+
+	messages := make([][]byte, 0, len(recipients))
+	for _, to := range recipients {
+		if attachment == nil {
+			messages = append(messages, mailqueue.BuildHTMLEmailMessage(from, to, subject, body))
+			continue
+		}
+		messages = append(messages, mailqueue.BuildEmailMessageWithAttachment(from, to, subject, body, *attachment))
+	}
+	return messages, nil
+}
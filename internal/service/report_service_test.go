@@ -0,0 +1,232 @@
+package service
+
+import (
+	"bytes"
+	"image/png"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/models"
+)
+
+func reportTestTickets() []models.ReportTicket {
+	day := func(d, h int) time.Time { return time.Date(2025, 3, d, h, 0, 0, 0, time.UTC) }
+	return []models.ReportTicket{
+		{CreateTime: day(3, 8), ChangeTime: day(3, 12), Closed: true, Queue: "Support"},
+		{CreateTime: day(3, 9), ChangeTime: day(4, 9), Closed: true, Queue: "Support"},
+		{CreateTime: day(4, 10), ChangeTime: day(4, 11), Closed: true, Queue: "Sales"},
+		{CreateTime: day(11, 10), ChangeTime: day(11, 20), Closed: true, Queue: ""},
+	}
+}
+
+func TestAggregateReport(t *testing.T) {
+	from := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 30)
+
+	t.Run("created by queue", func(t *testing.T) {
+		def := &models.ReportDefinition{ID: 7, Name: "Load", Metric: models.ReportMetricTicketsCreated,
+			GroupBy: models.ReportGroupQueue, TimeBucket: models.ReportBucketNone}
+		result := AggregateReport(def, reportTestTickets(), from, to)
+
+		assert.Equal(t, 7, result.ReportID)
+		assert.Equal(t, []models.ReportRow{
+			{Group: "(none)", Value: 1, Count: 1},
+			{Group: "Sales", Value: 1, Count: 1},
+			{Group: "Support", Value: 2, Count: 2},
+		}, result.Rows)
+	})
+
+	t.Run("created per day", func(t *testing.T) {
+		def := &models.ReportDefinition{Metric: models.ReportMetricTicketsCreated,
+			GroupBy: models.ReportGroupNone, TimeBucket: models.ReportBucketDay}
+		result := AggregateReport(def, reportTestTickets(), from, to)
+
+		assert.Equal(t, []models.ReportRow{
+			{Bucket: "2025-03-03", Value: 2, Count: 2},
+			{Bucket: "2025-03-04", Value: 1, Count: 1},
+			{Bucket: "2025-03-11", Value: 1, Count: 1},
+		}, result.Rows)
+	})
+
+	t.Run("closed buckets use close time", func(t *testing.T) {
+		def := &models.ReportDefinition{Metric: models.ReportMetricTicketsClosed,
+			GroupBy: models.ReportGroupNone, TimeBucket: models.ReportBucketDay}
+		result := AggregateReport(def, reportTestTickets(), from, to)
+
+		require.Len(t, result.Rows, 3)
+		assert.Equal(t, "2025-03-04", result.Rows[1].Bucket)
+		assert.Equal(t, 2.0, result.Rows[1].Value)
+	})
+
+	t.Run("average resolution per week", func(t *testing.T) {
+		def := &models.ReportDefinition{Metric: models.ReportMetricAvgResolutionHours,
+			GroupBy: models.ReportGroupNone, TimeBucket: models.ReportBucketWeek}
+		result := AggregateReport(def, reportTestTickets(), from, to)
+
+		assert.Equal(t, []models.ReportRow{
+			{Bucket: "2025-W10", Value: 9.67, Count: 3},
+			{Bucket: "2025-W11", Value: 10, Count: 1},
+		}, result.Rows)
+	})
+
+	t.Run("average satisfaction by queue", func(t *testing.T) {
+		tickets := reportTestTickets()
+		for i, rating := range []float64{5, 3, 4, 2} {
+			tickets[i].Rating = rating
+		}
+		def := &models.ReportDefinition{Metric: models.ReportMetricAvgSatisfaction,
+			GroupBy: models.ReportGroupQueue, TimeBucket: models.ReportBucketNone}
+		result := AggregateReport(def, tickets, from, to)
+
+		assert.Equal(t, []models.ReportRow{
+			{Group: "(none)", Value: 2, Count: 1},
+			{Group: "Sales", Value: 4, Count: 1},
+			{Group: "Support", Value: 4, Count: 2},
+		}, result.Rows)
+		assert.Equal(t, []string{"Queue", "Avg. satisfaction (1-5)", "Responses"}, reportColumns(&result))
+	})
+
+	t.Run("no tickets", func(t *testing.T) {
+		def := &models.ReportDefinition{Metric: models.ReportMetricTicketsCreated,
+			GroupBy: models.ReportGroupNone, TimeBucket: models.ReportBucketNone}
+		result := AggregateReport(def, nil, from, to)
+		assert.NotNil(t, result.Rows)
+		assert.Empty(t, result.Rows)
+	})
+}
+
+func TestReportBucketKey(t *testing.T) {
+	ts := time.Date(2024, 12, 30, 15, 0, 0, 0, time.UTC)
+	assert.Equal(t, "2024-12-30", reportBucketKey(ts, models.ReportBucketDay))
+	assert.Equal(t, "2025-W01", reportBucketKey(ts, models.ReportBucketWeek))
+	assert.Equal(t, "2024-12", reportBucketKey(ts, models.ReportBucketMonth))
+	assert.Equal(t, "", reportBucketKey(ts, models.ReportBucketNone))
+}
+
+func TestValidateReportDefinition(t *testing.T) {
+	def := &models.ReportDefinition{Metric: models.ReportMetricTicketsClosed,
+		ScheduleFormat: models.ReportFormatCSV, RecipientGroupIDs: []int{1}}
+	require.NoError(t, ValidateReportDefinition(def))
+	assert.Equal(t, models.ReportGroupNone, def.GroupBy)
+	assert.Equal(t, models.ReportBucketNone, def.TimeBucket)
+	assert.Empty(t, def.ScheduleFormat, "unscheduled reports drop delivery settings")
+	assert.Nil(t, def.RecipientGroupIDs)
+
+	def = &models.ReportDefinition{Metric: models.ReportMetricTicketsClosed, Schedule: "0 8 * * 1", RecipientGroupIDs: []int{2}}
+	require.NoError(t, ValidateReportDefinition(def))
+	assert.Equal(t, models.ReportFormatTable, def.ScheduleFormat)
+
+	for name, tc := range map[string]struct {
+		def  models.ReportDefinition
+		want error
+	}{
+		"metric":     {models.ReportDefinition{Metric: "tickets_open"}, ErrReportInvalidMetric},
+		"grouping":   {models.ReportDefinition{Metric: models.ReportMetricTicketsCreated, GroupBy: "agent"}, ErrReportInvalidGrouping},
+		"bucket":     {models.ReportDefinition{Metric: models.ReportMetricTicketsCreated, TimeBucket: "hour"}, ErrReportInvalidBucket},
+		"period":     {models.ReportDefinition{Metric: models.ReportMetricTicketsCreated, Filters: models.ReportFilters{PeriodDays: 400}}, ErrReportInvalidPeriod},
+		"schedule":   {models.ReportDefinition{Metric: models.ReportMetricTicketsCreated, Schedule: "every monday", RecipientGroupIDs: []int{1}}, ErrReportInvalidSchedule},
+		"format":     {models.ReportDefinition{Metric: models.ReportMetricTicketsCreated, Schedule: "@daily", ScheduleFormat: "pdf", RecipientGroupIDs: []int{1}}, ErrReportInvalidFormat},
+		"recipients": {models.ReportDefinition{Metric: models.ReportMetricTicketsCreated, Schedule: "@daily"}, ErrReportRecipientsRequired},
+	} {
+		t.Run(name, func(t *testing.T) {
+			def := tc.def
+			assert.ErrorIs(t, ValidateReportDefinition(&def), tc.want)
+		})
+	}
+}
+
+func TestDueReports(t *testing.T) {
+	now := time.Date(2025, 3, 10, 8, 30, 0, 0, time.UTC)
+	sentToday := time.Date(2025, 3, 10, 8, 0, 0, 0, time.UTC)
+	sentYesterday := time.Date(2025, 3, 9, 8, 0, 0, 0, time.UTC)
+
+	defs := []models.ReportDefinition{
+		{ID: 1, Schedule: "0 8 * * *", LastSentTime: &sentYesterday},
+		{ID: 2, Schedule: "0 8 * * *", LastSentTime: &sentToday},
+		{ID: 3, Schedule: "0 8 * * *", CreateTime: now.Add(-time.Hour)},
+		{ID: 4, Schedule: "0 8 * * *", CreateTime: now.Add(-10 * time.Minute)},
+		{ID: 5, Schedule: ""},
+		{ID: 6, Schedule: "not cron", LastSentTime: &sentYesterday},
+	}
+
+	var ids []int
+	for _, def := range DueReports(defs, now) {
+		ids = append(ids, def.ID)
+	}
+	assert.Equal(t, []int{1, 3}, ids)
+
+	next, err := ReportNextRun(defs[1], now)
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2025, 3, 11, 8, 0, 0, 0, time.UTC), next)
+}
+
+func TestRenderReportCSVAndHTML(t *testing.T) {
+	result := &models.ReportResult{
+		Name:       "Resolution <weekly>",
+		Metric:     models.ReportMetricAvgResolutionHours,
+		GroupBy:    models.ReportGroupQueue,
+		TimeBucket: models.ReportBucketWeek,
+		Rows:       []models.ReportRow{{Bucket: "2025-W10", Group: "Support", Value: 9.5, Count: 2}},
+	}
+
+	data, err := RenderReportCSV(result)
+	require.NoError(t, err)
+	assert.Equal(t, "Period,Queue,Avg. resolution (hours),Tickets\n2025-W10,Support,9.5,2\n", string(data))
+
+	html := RenderReportHTML(result)
+	assert.Contains(t, html, "<h2>Resolution &lt;weekly&gt;</h2>")
+	assert.Contains(t, html, "<td>Support</td><td>9.5</td>")
+}
+
+func TestRenderReportPNG(t *testing.T) {
+	for name, result := range map[string]*models.ReportResult{
+		"grouped": {Name: "By queue", Metric: models.ReportMetricTicketsCreated, GroupBy: models.ReportGroupQueue,
+			TimeBucket: models.ReportBucketNone, Rows: []models.ReportRow{{Group: "Sales", Value: 3}, {Group: "Support", Value: 12}}},
+		"bucketed series": {Name: "Per day", Metric: models.ReportMetricTicketsClosed, GroupBy: models.ReportGroupQueue,
+			TimeBucket: models.ReportBucketDay, Rows: []models.ReportRow{
+				{Bucket: "2025-03-03", Group: "Sales", Value: 1}, {Bucket: "2025-03-03", Group: "Support", Value: 4},
+				{Bucket: "2025-03-04", Group: "Support", Value: 2}}},
+		"empty": {Name: "Nothing", Metric: models.ReportMetricTicketsCreated, GroupBy: models.ReportGroupNone,
+			TimeBucket: models.ReportBucketNone, Rows: []models.ReportRow{}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			data, err := RenderReportPNG(result)
+			require.NoError(t, err)
+			img, err := png.Decode(bytes.NewReader(data))
+			require.NoError(t, err)
+			assert.Equal(t, reportChartHeight, img.Bounds().Dy())
+			assert.GreaterOrEqual(t, img.Bounds().Dx(), reportChartMinWidth)
+		})
+	}
+}
+
+func TestChartNiceCeil(t *testing.T) {
+	assert.Equal(t, 1.0, chartNiceCeil(0))
+	assert.Equal(t, 2.0, chartNiceCeil(1.5))
+	assert.Equal(t, 50.0, chartNiceCeil(42))
+	assert.Equal(t, 100.0, chartNiceCeil(100))
+}
+
+func TestBuildReportEmails(t *testing.T) {
+	result := &models.ReportResult{Name: "Weekly", Metric: models.ReportMetricTicketsCreated,
+		GroupBy: models.ReportGroupNone, TimeBucket: models.ReportBucketNone,
+		To: time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC), Rows: []models.ReportRow{{Value: 4, Count: 4}}}
+	recipients := []string{"a@example.com", "b@example.com"}
+
+	messages, err := buildReportEmails(result, models.ReportFormatTable, "noreply@example.com", recipients)
+	require.NoError(t, err)
+	require.Len(t, messages, 2)
+	assert.Contains(t, string(messages[1]), "To: b@example.com")
+	assert.Contains(t, string(messages[0]), "Subject: Report: Weekly")
+	assert.NotContains(t, string(messages[0]), "multipart/mixed")
+
+	messages, err = buildReportEmails(result, models.ReportFormatCSV, "noreply@example.com", recipients[:1])
+	require.NoError(t, err)
+	require.Len(t, messages, 1)
+	msg := string(messages[0])
+	assert.Contains(t, msg, "multipart/mixed")
+	assert.Contains(t, msg, `filename="report-20250310.csv"`)
+}
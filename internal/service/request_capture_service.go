@@ -0,0 +1,830 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/models"
+)
+
+// Request capture defaults, used when the options leave them unset.
+const (
+	DefaultCaptureMaxBodyBytes = 64 << 10
+	DefaultCaptureRetention    = 7 * 24 * time.Hour
+	DefaultReplayTimeout       = 30 * time.Second
+
+	// MaxCaptureDuration bounds how long a capture may record.
+	MaxCaptureDuration = 7 * 24 * time.Hour
+	// MaxCaptureRequests bounds how many requests one capture may record.
+	MaxCaptureRequests = 1000
+
+	// ReplayHeader marks replayed requests, so a capture on the target
+	// does not record them again.
+	ReplayHeader = "X-GoatFlow-Replay"
+
+	// RedactedValue replaces secrets in captured requests and responses.
+	RedactedValue = "[REDACTED]"
+
+	requestCaptureCacheTTL = 10 * time.Second
+	maxReplayResponseSize  = 1 << 20
+	maxResponseDifferences = 200
+)
+
+// Errors returned by RequestCaptureService.
+var (
+	ErrRequestCaptureNotFound  = errors.New("request capture not found")
+	ErrCapturedRequestNotFound = errors.New("captured request not found")
+	ErrRequestCaptureName      = errors.New("name is required")
+	ErrRequestCaptureMethod    = errors.New("method must be GET, POST, PUT, PATCH or DELETE")
+	ErrRequestCapturePath      = errors.New("path_prefix must start with /api/v1")
+	ErrRequestCapturePrincipal = errors.New("principal must be token:<id>, user:<id> or customer:<id>")
+	ErrRequestCaptureLimit     = fmt.Errorf("max_requests must be between 1 and %d", MaxCaptureRequests)
+	ErrRequestCaptureExpiry    = errors.New("a capture must end within 7 days")
+	ErrReplayTargetUnknown     = errors.New("unknown replay target")
+)
+
+var capturePrincipal = regexp.MustCompile(`^(token|user|customer):[0-9]+$`)
+
+// Headers whose values are never stored.
+var sensitiveHeaders = map[string]bool{
+	"authorization":       true,
+	"proxy-authorization": true,
+	"cookie":              true,
+	"set-cookie":          true,
+	"x-api-key":           true,
+	"x-csrf-token":        true,
+}
+
+// Headers not sent when replaying; the HTTP client sets them itself.
+var replaySkippedHeaders = map[string]bool{
+	"host":              true,
+	"content-length":    true,
+	"connection":        true,
+	"accept-encoding":   true,
+	"transfer-encoding": true,
+	"te":                true,
+	"upgrade":           true,
+	"keep-alive":        true,
+}
+
+// Parts of field and header names that mark their values as secret, after
+// lowercasing and removing dashes and underscores.
+var sensitiveNameParts = []string{
+	"password", "passwd", "secret", "token", "apikey", "authorization",
+	"credential", "privatekey", "cookie", "signature",
+}
+
+// Field names that are secret only as a whole, being too short to match
+// as part of other names.
+var sensitiveNames = map[string]bool{"otp": true, "totp": true, "pin": true, "session": true}
+
+// RequestCaptureOptions configures a RequestCaptureService.
+type RequestCaptureOptions struct {
+	// MaxBodyBytes is where stored bodies are cut off.
+	MaxBodyBytes int
+	// Retention is how long captured requests are kept.
+	Retention time.Duration
+	// RedactFields are extra JSON, form and query field names to redact.
+	RedactFields []string
+	// ReplayTargets maps target names to the base URLs requests can be
+	// replayed against.
+	ReplayTargets map[string]string
+	ReplayTimeout time.Duration
+}
+
+// CapturedExchange is a request and its response as seen by the capture
+// middleware, before redaction.
+type CapturedExchange struct {
+	Principal      string
+	Method         string
+	Path           string
+	Query          string
+	RequestHeader  http.Header
+	RequestBody    []byte
+	ResponseHeader http.Header
+	StatusCode     int
+	ResponseBody   []byte
+	// BodyOmitted is set when the middleware did not buffer a body, for
+	// uploads and responses above its size limit.
+	BodyOmitted bool
+	Duration    time.Duration
+}
+
+// ReplayRequest describes how to replay a captured request.
+type ReplayRequest struct {
+	// Target is a name from RequestCaptureOptions.ReplayTargets.
+	Target string
+	// Token is sent as bearer token to the target. It is not stored.
+	Token string
+	// Body replaces the captured body, e.g. to fill in redacted values.
+	Body *string
+	// Ignore lists JSON paths (data.updated_at) or field names (updated_at)
+	// left out of the comparison.
+	Ignore []string
+}
+
+type requestCaptureCache struct {
+	mu       sync.Mutex
+	captures []models.RequestCapture
+	expires  time.Time
+}
+
+// activeCaptures caches the captures that are recording, since every API
+// request checks them. Changes on this instance clear it at once.
+var activeCaptures requestCaptureCache
+
+func clearRequestCaptureCache() {
+	activeCaptures.mu.Lock()
+	activeCaptures.expires = time.Time{}
+	activeCaptures.captures = nil
+	activeCaptures.mu.Unlock()
+}
+
+// RequestCaptureService records API requests matching admin-defined
+// captures, with secrets redacted, and replays them against configured
+// targets to compare the responses with the captured ones.
+type RequestCaptureService struct {
+	db     *sql.DB
+	opts   RequestCaptureOptions
+	client *http.Client
+	now    func() time.Time
+}
+
+// NewRequestCaptureService creates a request capture service. Unset options
+// use the Default* constants.
+func NewRequestCaptureService(db *sql.DB, opts RequestCaptureOptions) *RequestCaptureService {
+	if opts.MaxBodyBytes <= 0 {
+		opts.MaxBodyBytes = DefaultCaptureMaxBodyBytes
+	}
+	if opts.Retention <= 0 {
+		opts.Retention = DefaultCaptureRetention
+	}
+	if opts.ReplayTimeout <= 0 {
+		opts.ReplayTimeout = DefaultReplayTimeout
+	}
+	return &RequestCaptureService{
+		db:   db,
+		opts: opts,
+		client: &http.Client{
+			Timeout: opts.ReplayTimeout,
+			// Redirects are part of the response being compared.
+			CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		},
+		now: time.Now,
+	}
+}
+
+// ReplayTargets returns the names of the configured replay targets.
+func (s *RequestCaptureService) ReplayTargets() []string {
+	names := make([]string, 0, len(s.opts.ReplayTargets))
+	for name := range s.opts.ReplayTargets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+const requestCaptureSelect = `
+	SELECT c.id, c.name, COALESCE(c.method, ''), c.path_prefix, COALESCE(c.principal, ''), c.max_requests,
+	       (SELECT COUNT(*) FROM api_captured_request r WHERE r.capture_id = c.id),
+	       c.expires_time, c.valid_id, c.create_time, c.create_by, c.change_time, c.change_by
+	FROM api_request_capture c`
+
+func scanRequestCapture(row interface{ Scan(...interface{}) error }) (*models.RequestCapture, error) {
+	var rc models.RequestCapture
+	err := row.Scan(&rc.ID, &rc.Name, &rc.Method, &rc.PathPrefix, &rc.Principal, &rc.MaxRequests, &rc.Captured,
+		&rc.ExpiresTime, &rc.ValidID, &rc.CreateTime, &rc.CreateBy, &rc.ChangeTime, &rc.ChangeBy)
+	if err != nil {
+		return nil, err
+	}
+	return &rc, nil
+}
+
+func (s *RequestCaptureService) queryCaptures(ctx context.Context, where string, args ...interface{}) ([]models.RequestCapture, error) {
+	rows, err := s.db.QueryContext(ctx, database.ConvertPlaceholders(requestCaptureSelect+where), args...)
+	if err != nil {
+		return nil, fmt.Errorf("query request captures: %w", err)
+	}
+	defer rows.Close()
+
+	captures := []models.RequestCapture{}
+	for rows.Next() {
+		rc, err := scanRequestCapture(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan request capture: %w", err)
+		}
+		captures = append(captures, *rc)
+	}
+	return captures, rows.Err()
+}
+
+// ListCaptures returns every capture, newest first.
+func (s *RequestCaptureService) ListCaptures(ctx context.Context) ([]models.RequestCapture, error) {
+	return s.queryCaptures(ctx, " ORDER BY c.id DESC")
+}
+
+// GetCapture returns a capture by ID.
+func (s *RequestCaptureService) GetCapture(ctx context.Context, id int64) (*models.RequestCapture, error) {
+	captures, err := s.queryCaptures(ctx, " WHERE c.id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(captures) == 0 {
+		return nil, ErrRequestCaptureNotFound
+	}
+	return &captures[0], nil
+}
+
+// CreateCapture validates and stores a capture. It starts recording at once.
+func (s *RequestCaptureService) CreateCapture(ctx context.Context, rc *models.RequestCapture, userID int) (*models.RequestCapture, error) {
+	now := s.now()
+	rc.Name = strings.TrimSpace(rc.Name)
+	rc.Method = strings.ToUpper(strings.TrimSpace(rc.Method))
+	rc.PathPrefix = strings.TrimSpace(rc.PathPrefix)
+	rc.Principal = strings.TrimSpace(rc.Principal)
+	if rc.PathPrefix == "" {
+		rc.PathPrefix = "/api/v1"
+	}
+	if rc.MaxRequests == 0 {
+		rc.MaxRequests = 100
+	}
+	if rc.ExpiresTime.IsZero() {
+		rc.ExpiresTime = now.Add(time.Hour)
+	}
+
+	switch {
+	case rc.Name == "":
+		return nil, ErrRequestCaptureName
+	case rc.Method != "" && rc.Method != http.MethodGet && rc.Method != http.MethodPost &&
+		rc.Method != http.MethodPut && rc.Method != http.MethodPatch && rc.Method != http.MethodDelete:
+		return nil, ErrRequestCaptureMethod
+	case rc.PathPrefix != "/api/v1" && !strings.HasPrefix(rc.PathPrefix, "/api/v1/"):
+		return nil, ErrRequestCapturePath
+	case rc.Principal != "" && !capturePrincipal.MatchString(rc.Principal):
+		return nil, ErrRequestCapturePrincipal
+	case rc.MaxRequests < 1 || rc.MaxRequests > MaxCaptureRequests:
+		return nil, ErrRequestCaptureLimit
+	case !rc.ExpiresTime.After(now) || rc.ExpiresTime.After(now.Add(MaxCaptureDuration)):
+		return nil, ErrRequestCaptureExpiry
+	}
+
+	id, err := database.GetAdapter().InsertWithReturning(s.db, database.ConvertPlaceholders(`
+		INSERT INTO api_request_capture (name, method, path_prefix, principal, max_requests, expires_time,
+			valid_id, create_time, create_by, change_time, change_by)
+		VALUES (?, ?, ?, ?, ?, ?, 1, ?, ?, ?, ?)
+		RETURNING id`),
+		rc.Name, nullIfEmpty(rc.Method), rc.PathPrefix, nullIfEmpty(rc.Principal), rc.MaxRequests,
+		rc.ExpiresTime, now, userID, now, userID)
+	if err != nil {
+		return nil, fmt.Errorf("create request capture: %w", err)
+	}
+	clearRequestCaptureCache()
+	return s.GetCapture(ctx, id)
+}
+
+// StopCapture ends a capture before it expires. Its requests are kept.
+func (s *RequestCaptureService) StopCapture(ctx context.Context, id int64, userID int) (*models.RequestCapture, error) {
+	result, err := s.db.ExecContext(ctx, database.ConvertPlaceholders(
+		"UPDATE api_request_capture SET valid_id = 2, change_time = ?, change_by = ? WHERE id = ?",
+	), s.now(), userID, id)
+	if err != nil {
+		return nil, fmt.Errorf("stop request capture: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 { //nolint:errcheck // drivers used here always report it
+		return nil, ErrRequestCaptureNotFound
+	}
+	clearRequestCaptureCache()
+	return s.GetCapture(ctx, id)
+}
+
+// DeleteCapture removes a capture with its requests and replays.
+func (s *RequestCaptureService) DeleteCapture(ctx context.Context, id int64) error {
+	if _, err := s.GetCapture(ctx, id); err != nil {
+		return err
+	}
+	for _, stmt := range []string{
+		"DELETE FROM api_request_replay WHERE captured_request_id IN (SELECT id FROM api_captured_request WHERE capture_id = ?)",
+		"DELETE FROM api_captured_request WHERE capture_id = ?",
+		"DELETE FROM api_request_capture WHERE id = ?",
+	} {
+		if _, err := s.db.ExecContext(ctx, database.ConvertPlaceholders(stmt), id); err != nil {
+			return fmt.Errorf("delete request capture: %w", err)
+		}
+	}
+	clearRequestCaptureCache()
+	return nil
+}
+
+// Match returns the capture recording a request by principal, or nil.
+func (s *RequestCaptureService) Match(ctx context.Context, method, path, principal string) (*models.RequestCapture, error) {
+	now := s.now()
+	activeCaptures.mu.Lock()
+	defer activeCaptures.mu.Unlock()
+	if now.After(activeCaptures.expires) {
+		captures, err := s.queryCaptures(ctx, " WHERE c.valid_id = 1 AND c.expires_time > ? ORDER BY c.id", now)
+		if err != nil {
+			return nil, err
+		}
+		activeCaptures.captures = captures
+		activeCaptures.expires = now.Add(requestCaptureCacheTTL)
+	}
+	for i := range activeCaptures.captures {
+		rc := &activeCaptures.captures[i]
+		if rc.Active(now) && rc.Matches(method, path, principal) {
+			match := *rc
+			return &match, nil
+		}
+	}
+	return nil, nil
+}
+
+// Record stores a request for the capture, redacting secrets and cutting
+// bodies at the size limit. Requests beyond the capture's limit are dropped.
+func (s *RequestCaptureService) Record(ctx context.Context, rc *models.RequestCapture, ex CapturedExchange) error {
+	var count int
+	if err := s.db.QueryRowContext(ctx, database.ConvertPlaceholders(
+		"SELECT COUNT(*) FROM api_captured_request WHERE capture_id = ?",
+	), rc.ID).Scan(&count); err != nil {
+		return fmt.Errorf("count captured requests: %w", err)
+	}
+	if count >= rc.MaxRequests {
+		clearRequestCaptureCache()
+		return nil
+	}
+
+	reqBody, reqCut := s.redactBody(ex.RequestHeader.Get("Content-Type"), ex.RequestBody)
+	respBody, respCut := s.redactBody(ex.ResponseHeader.Get("Content-Type"), ex.ResponseBody)
+	reqHeaders, err := json.Marshal(s.redactHeaders(ex.RequestHeader))
+	if err != nil {
+		return err
+	}
+	respHeaders, err := json.Marshal(s.redactHeaders(ex.ResponseHeader))
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.ExecContext(ctx, database.ConvertPlaceholders(`
+		INSERT INTO api_captured_request (capture_id, principal, method, path, query_string, request_headers,
+			request_body, status_code, response_headers, response_body, duration_ms, truncated, create_time)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`), rc.ID, ex.Principal, ex.Method, ex.Path, s.redactQuery(ex.Query), string(reqHeaders), reqBody,
+		ex.StatusCode, string(respHeaders), respBody, ex.Duration.Milliseconds(),
+		boolToSmallint(reqCut || respCut || ex.BodyOmitted), s.now())
+	if err != nil {
+		return fmt.Errorf("store captured request: %w", err)
+	}
+	if count+1 >= rc.MaxRequests {
+		clearRequestCaptureCache()
+	}
+	return nil
+}
+
+const capturedRequestSelect = `
+	SELECT id, capture_id, principal, method, path, COALESCE(query_string, ''), COALESCE(request_headers, ''),
+	       COALESCE(request_body, ''), status_code, COALESCE(response_headers, ''), COALESCE(response_body, ''),
+	       duration_ms, truncated, create_time
+	FROM api_captured_request`
+
+func scanCapturedRequest(row interface{ Scan(...interface{}) error }) (*models.CapturedRequest, error) {
+	var r models.CapturedRequest
+	var reqHeaders, respHeaders string
+	var truncated int
+	err := row.Scan(&r.ID, &r.CaptureID, &r.Principal, &r.Method, &r.Path, &r.Query, &reqHeaders, &r.RequestBody,
+		&r.StatusCode, &respHeaders, &r.ResponseBody, &r.DurationMS, &truncated, &r.CreateTime)
+	if err != nil {
+		return nil, err
+	}
+	r.Truncated = truncated == 1
+	r.RequestHeaders = map[string]string{}
+	r.ResponseHeaders = map[string]string{}
+	if reqHeaders != "" {
+		_ = json.Unmarshal([]byte(reqHeaders), &r.RequestHeaders) //nolint:errcheck // written by Record
+	}
+	if respHeaders != "" {
+		_ = json.Unmarshal([]byte(respHeaders), &r.ResponseHeaders) //nolint:errcheck // written by Record
+	}
+	return &r, nil
+}
+
+// ListRequests returns the requests recorded by a capture, oldest first.
+func (s *RequestCaptureService) ListRequests(ctx context.Context, captureID int64) ([]models.CapturedRequest, error) {
+	if _, err := s.GetCapture(ctx, captureID); err != nil {
+		return nil, err
+	}
+	rows, err := s.db.QueryContext(ctx, database.ConvertPlaceholders(capturedRequestSelect+" WHERE capture_id = ? ORDER BY id"), captureID)
+	if err != nil {
+		return nil, fmt.Errorf("query captured requests: %w", err)
+	}
+	defer rows.Close()
+
+	requests := []models.CapturedRequest{}
+	for rows.Next() {
+		r, err := scanCapturedRequest(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan captured request: %w", err)
+		}
+		requests = append(requests, *r)
+	}
+	return requests, rows.Err()
+}
+
+// GetRequest returns a captured request by ID.
+func (s *RequestCaptureService) GetRequest(ctx context.Context, id int64) (*models.CapturedRequest, error) {
+	r, err := scanCapturedRequest(s.db.QueryRowContext(ctx, database.ConvertPlaceholders(capturedRequestSelect+" WHERE id = ?"), id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrCapturedRequestNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("get captured request: %w", err)
+	}
+	return r, nil
+}
+
+// Replay sends a captured request to a replay target and stores the
+// response with its differences to the captured one. A target that cannot
+// be reached is recorded as a replay with an error, not returned as one.
+func (s *RequestCaptureService) Replay(ctx context.Context, requestID int64, rr ReplayRequest, userID int) (*models.RequestReplay, error) {
+	baseURL, ok := s.opts.ReplayTargets[rr.Target]
+	if !ok {
+		return nil, ErrReplayTargetUnknown
+	}
+	captured, err := s.GetRequest(ctx, requestID)
+	if err != nil {
+		return nil, err
+	}
+
+	replay := &models.RequestReplay{CapturedRequestID: requestID, Target: rr.Target, Differences: []models.ResponseDifference{}}
+	start := s.now()
+	status, contentType, body, sendErr := s.send(ctx, strings.TrimSuffix(baseURL, "/"), captured, rr)
+	replay.DurationMS = s.now().Sub(start).Milliseconds()
+	if sendErr != nil {
+		replay.Error = sendErr.Error()
+	} else {
+		replay.StatusCode = status
+		// Redacted the same way as the capture, so secrets compare equal.
+		replay.ResponseBody, _ = s.redactBody(contentType, body)
+		replay.Differences = diffResponses(captured.StatusCode, captured.ResponseBody, status, replay.ResponseBody, rr.Ignore)
+	}
+
+	diff, err := json.Marshal(replay.Differences)
+	if err != nil {
+		return nil, err
+	}
+	replay.CreateTime = s.now()
+	replay.CreateBy = userID
+	replay.ID, err = database.GetAdapter().InsertWithReturning(s.db, database.ConvertPlaceholders(`
+		INSERT INTO api_request_replay (captured_request_id, target, status_code, response_body, diff,
+			error_message, duration_ms, create_time, create_by)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id`),
+		requestID, rr.Target, replay.StatusCode, replay.ResponseBody, string(diff),
+		nullIfEmpty(truncateString(replay.Error, 1000)), replay.DurationMS, replay.CreateTime, userID)
+	if err != nil {
+		return nil, fmt.Errorf("store replay: %w", err)
+	}
+	return replay, nil
+}
+
+func (s *RequestCaptureService) send(ctx context.Context, baseURL string, captured *models.CapturedRequest, rr ReplayRequest) (int, string, []byte, error) {
+	target := baseURL + captured.Path
+	if captured.Query != "" {
+		target += "?" + captured.Query
+	}
+	body := captured.RequestBody
+	if rr.Body != nil {
+		body = *rr.Body
+	}
+	req, err := http.NewRequestWithContext(ctx, captured.Method, target, strings.NewReader(body))
+	if err != nil {
+		return 0, "", nil, err
+	}
+	for name, value := range captured.RequestHeaders {
+		if value == RedactedValue || replaySkippedHeaders[strings.ToLower(name)] {
+			continue
+		}
+		req.Header.Set(name, value)
+	}
+	if rr.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+rr.Token)
+	}
+	req.Header.Set(ReplayHeader, strconv.FormatInt(captured.ID, 10))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, "", nil, err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, maxReplayResponseSize))
+	if err != nil {
+		return 0, "", nil, err
+	}
+	return resp.StatusCode, resp.Header.Get("Content-Type"), respBody, nil
+}
+
+// ListReplays returns the replays of a captured request, newest first.
+func (s *RequestCaptureService) ListReplays(ctx context.Context, requestID int64) ([]models.RequestReplay, error) {
+	if _, err := s.GetRequest(ctx, requestID); err != nil {
+		return nil, err
+	}
+	rows, err := s.db.QueryContext(ctx, database.ConvertPlaceholders(`
+		SELECT id, captured_request_id, target, status_code, COALESCE(response_body, ''), COALESCE(diff, ''),
+		       COALESCE(error_message, ''), duration_ms, create_time, create_by
+		FROM api_request_replay
+		WHERE captured_request_id = ?
+		ORDER BY id DESC
+	`), requestID)
+	if err != nil {
+		return nil, fmt.Errorf("query replays: %w", err)
+	}
+	defer rows.Close()
+
+	replays := []models.RequestReplay{}
+	for rows.Next() {
+		var r models.RequestReplay
+		var diff string
+		if err := rows.Scan(&r.ID, &r.CapturedRequestID, &r.Target, &r.StatusCode, &r.ResponseBody, &diff,
+			&r.Error, &r.DurationMS, &r.CreateTime, &r.CreateBy); err != nil {
+			return nil, fmt.Errorf("scan replay: %w", err)
+		}
+		r.Differences = []models.ResponseDifference{}
+		if diff != "" {
+			_ = json.Unmarshal([]byte(diff), &r.Differences) //nolint:errcheck // written by Replay
+		}
+		replays = append(replays, r)
+	}
+	return replays, rows.Err()
+}
+
+// PurgeExpired removes requests older than the retention, with their
+// replays, and captures that ended before it without requests left. It
+// returns how many requests were removed.
+func (s *RequestCaptureService) PurgeExpired(ctx context.Context) (int64, error) {
+	cutoff := s.now().Add(-s.opts.Retention)
+	if _, err := s.db.ExecContext(ctx, database.ConvertPlaceholders(
+		"DELETE FROM api_request_replay WHERE captured_request_id IN (SELECT id FROM api_captured_request WHERE create_time <= ?)",
+	), cutoff); err != nil {
+		return 0, fmt.Errorf("purge replays: %w", err)
+	}
+	result, err := s.db.ExecContext(ctx, database.ConvertPlaceholders(
+		"DELETE FROM api_captured_request WHERE create_time <= ?",
+	), cutoff)
+	if err != nil {
+		return 0, fmt.Errorf("purge captured requests: %w", err)
+	}
+	if _, err := s.db.ExecContext(ctx, database.ConvertPlaceholders(`
+		DELETE FROM api_request_capture
+		WHERE expires_time <= ?
+		  AND NOT EXISTS (SELECT 1 FROM api_captured_request r WHERE r.capture_id = api_request_capture.id)
+	`), cutoff); err != nil {
+		return 0, fmt.Errorf("purge request captures: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// sensitiveName reports whether a field or header with this name holds a
+// secret.
+func (s *RequestCaptureService) sensitiveName(name string) bool {
+	n := strings.NewReplacer("_", "", "-", "").Replace(strings.ToLower(name))
+	if sensitiveNames[n] {
+		return true
+	}
+	for _, part := range sensitiveNameParts {
+		if strings.Contains(n, part) {
+			return true
+		}
+	}
+	for _, field := range s.opts.RedactFields {
+		if strings.EqualFold(name, field) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *RequestCaptureService) redactHeaders(h http.Header) map[string]string {
+	out := make(map[string]string, len(h))
+	for name, values := range h {
+		if sensitiveHeaders[strings.ToLower(name)] || s.sensitiveName(name) {
+			out[name] = RedactedValue
+		} else {
+			out[name] = strings.Join(values, ", ")
+		}
+	}
+	return out
+}
+
+func (s *RequestCaptureService) redactQuery(query string) string {
+	if query == "" {
+		return ""
+	}
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		return RedactedValue
+	}
+	s.redactValues(values)
+	return values.Encode()
+}
+
+func (s *RequestCaptureService) redactValues(values url.Values) {
+	for name, vs := range values {
+		if s.sensitiveName(name) {
+			for i := range vs {
+				vs[i] = RedactedValue
+			}
+		}
+	}
+}
+
+// redactBody returns the body with secrets replaced, cut at the size limit,
+// and whether anything was cut or left out. Bodies that are neither JSON,
+// form data nor text are left out.
+func (s *RequestCaptureService) redactBody(contentType string, body []byte) (string, bool) {
+	if len(body) == 0 {
+		return "", false
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType) //nolint:errcheck // empty on error
+	var out string
+	switch {
+	case strings.Contains(mediaType, "json") || (mediaType == "" && json.Valid(body)):
+		dec := json.NewDecoder(bytes.NewReader(body))
+		dec.UseNumber()
+		var v interface{}
+		if err := dec.Decode(&v); err != nil {
+			// Unparseable JSON may hide secrets anywhere.
+			return "", true
+		}
+		redacted, err := json.Marshal(s.redactJSON(v))
+		if err != nil {
+			return "", true
+		}
+		out = string(redacted)
+	case mediaType == "application/x-www-form-urlencoded":
+		values, err := url.ParseQuery(string(body))
+		if err != nil {
+			return "", true
+		}
+		s.redactValues(values)
+		out = values.Encode()
+	case strings.HasPrefix(mediaType, "text/") || strings.HasSuffix(mediaType, "xml"):
+		out = string(body)
+	default:
+		return "", true
+	}
+	if len(out) > s.opts.MaxBodyBytes {
+		return out[:s.opts.MaxBodyBytes], true
+	}
+	return out, false
+}
+
+func (s *RequestCaptureService) redactJSON(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, child := range t {
+			if s.sensitiveName(k) && child != nil {
+				t[k] = RedactedValue
+			} else {
+				t[k] = s.redactJSON(child)
+			}
+		}
+	case []interface{}:
+		for i := range t {
+			t[i] = s.redactJSON(t[i])
+		}
+	}
+	return v
+}
+
+// diffResponses compares a captured response with its replay. JSON bodies
+// are compared field by field; other bodies as a whole.
+func diffResponses(beforeStatus int, beforeBody string, afterStatus int, afterBody string, ignore []string) []models.ResponseDifference {
+	diffs := []models.ResponseDifference{}
+	if beforeStatus != afterStatus {
+		diffs = append(diffs, models.ResponseDifference{Path: "status", Before: beforeStatus, After: afterStatus})
+	}
+	before, beforeErr := decodeJSONBody(beforeBody)
+	after, afterErr := decodeJSONBody(afterBody)
+	if beforeErr != nil || afterErr != nil {
+		if beforeBody != afterBody {
+			diffs = append(diffs, models.ResponseDifference{Path: "body", Before: beforeBody, After: afterBody})
+		}
+		return diffs
+	}
+	diffJSON("", before, after, ignore, &diffs)
+	return diffs
+}
+
+func decodeJSONBody(body string) (interface{}, error) {
+	if body == "" {
+		return nil, nil
+	}
+	dec := json.NewDecoder(strings.NewReader(body))
+	dec.UseNumber()
+	var v interface{}
+	err := dec.Decode(&v)
+	return v, err
+}
+
+func diffJSON(path string, before, after interface{}, ignore []string, diffs *[]models.ResponseDifference) {
+	if len(*diffs) >= maxResponseDifferences || ignoredPath(path, ignore) {
+		return
+	}
+	switch b := before.(type) {
+	case map[string]interface{}:
+		a, ok := after.(map[string]interface{})
+		if !ok {
+			break
+		}
+		keys := make([]string, 0, len(b)+len(a))
+		for k := range b {
+			keys = append(keys, k)
+		}
+		for k := range a {
+			if _, seen := b[k]; !seen {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			child := k
+			if path != "" {
+				child = path + "." + k
+			}
+			diffJSON(child, b[k], a[k], ignore, diffs)
+		}
+		return
+	case []interface{}:
+		a, ok := after.([]interface{})
+		if !ok {
+			break
+		}
+		for i := 0; i < len(b) || i < len(a); i++ {
+			var bv, av interface{}
+			if i < len(b) {
+				bv = b[i]
+			}
+			if i < len(a) {
+				av = a[i]
+			}
+			diffJSON(fmt.Sprintf("%s[%d]", path, i), bv, av, ignore, diffs)
+		}
+		return
+	}
+	if !reflect.DeepEqual(before, after) {
+		*diffs = append(*diffs, models.ResponseDifference{Path: path, Before: before, After: after})
+	}
+}
+
+// ignoredPath reports whether a JSON path is left out of the comparison:
+// it equals or lies below an ignored path, or ends in an ignored field name.
+func ignoredPath(path string, ignore []string) bool {
+	if path == "" {
+		return false
+	}
+	last := path[strings.LastIndex(path, ".")+1:]
+	if i := strings.Index(last, "["); i >= 0 {
+		last = last[:i]
+	}
+	for _, p := range ignore {
+		if path == p || strings.HasPrefix(path, p+".") || strings.HasPrefix(path, p+"[") || last == p {
+			return true
+		}
+	}
+	return false
+}
+
+func nullIfEmpty(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+func boolToSmallint(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func truncateString(s string, n int) string {
+	if len(s) > n {
+		return s[:n]
+	}
+	return s
+}
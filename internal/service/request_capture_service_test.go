@@ -0,0 +1,227 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/testutil"
+)
+
+func newRequestCaptureTestService(t *testing.T, targets map[string]string) *RequestCaptureService {
+	t.Helper()
+	db := testutil.MigratedDB(t)
+	clearRequestCaptureCache()
+	t.Cleanup(clearRequestCaptureCache)
+
+	_, err := db.Exec(`INSERT INTO users (id, login, pw, first_name, last_name, valid_id, create_time, create_by, change_time, change_by)
+		VALUES (1, 'root@localhost', 'x', 'Admin', 'OTRS', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`)
+	require.NoError(t, err)
+	return NewRequestCaptureService(db, RequestCaptureOptions{
+		MaxBodyBytes:  200,
+		RedactFields:  []string{"ssn"},
+		ReplayTargets: targets,
+	})
+}
+
+func TestRequestCaptureService_Validate(t *testing.T) {
+	svc := newRequestCaptureTestService(t, nil)
+	ctx := context.Background()
+	now := time.Now()
+
+	tests := []struct {
+		name    string
+		capture models.RequestCapture
+		want    error
+	}{
+		{"no name", models.RequestCapture{}, ErrRequestCaptureName},
+		{"bad method", models.RequestCapture{Name: "x", Method: "TRACE"}, ErrRequestCaptureMethod},
+		{"outside api", models.RequestCapture{Name: "x", PathPrefix: "/admin"}, ErrRequestCapturePath},
+		{"api prefix lookalike", models.RequestCapture{Name: "x", PathPrefix: "/api/v10"}, ErrRequestCapturePath},
+		{"bad principal", models.RequestCapture{Name: "x", Principal: "admin"}, ErrRequestCapturePrincipal},
+		{"too many", models.RequestCapture{Name: "x", MaxRequests: MaxCaptureRequests + 1}, ErrRequestCaptureLimit},
+		{"past", models.RequestCapture{Name: "x", ExpiresTime: now.Add(-time.Minute)}, ErrRequestCaptureExpiry},
+		{"too long", models.RequestCapture{Name: "x", ExpiresTime: now.Add(8 * 24 * time.Hour)}, ErrRequestCaptureExpiry},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rc := tt.capture
+			_, err := svc.CreateCapture(ctx, &rc, 1)
+			assert.ErrorIs(t, err, tt.want)
+		})
+	}
+}
+
+func TestRequestCaptureService_RecordRedacts(t *testing.T) {
+	svc := newRequestCaptureTestService(t, nil)
+	ctx := context.Background()
+
+	rc, err := svc.CreateCapture(ctx, &models.RequestCapture{
+		Name: "Zapier tickets", Method: "post", PathPrefix: "/api/v1/tickets", Principal: "token:7", MaxRequests: 2,
+	}, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "POST", rc.Method)
+
+	match, err := svc.Match(ctx, http.MethodPost, "/api/v1/tickets/12/articles", "token:7")
+	require.NoError(t, err)
+	require.NotNil(t, match)
+	for _, miss := range [][3]string{
+		{http.MethodGet, "/api/v1/tickets", "token:7"},
+		{http.MethodPost, "/api/v1/tickets", "token:8"},
+		{http.MethodPost, "/api/v1/ticketsearch", "token:7"},
+	} {
+		m, err := svc.Match(ctx, miss[0], miss[1], miss[2])
+		require.NoError(t, err)
+		assert.Nil(t, m, miss)
+	}
+
+	exchange := CapturedExchange{
+		Principal: "token:7",
+		Method:    http.MethodPost,
+		Path:      "/api/v1/tickets",
+		Query:     "access_token=abc&dry_run=1",
+		RequestHeader: http.Header{
+			"Authorization":   {"Bearer gf_secret"},
+			"Content-Type":    {"application/json"},
+			"X-Hub-Signature": {"sha256=00"},
+			"User-Agent":      {"Zapier"},
+		},
+		RequestBody:    []byte(`{"title":"Printer","customer":{"password":"hunter2","ssn":"123"},"api_token":null}`),
+		ResponseHeader: http.Header{"Content-Type": {"application/json; charset=utf-8"}, "Set-Cookie": {"s=1"}},
+		StatusCode:     http.StatusCreated,
+		ResponseBody:   []byte(`{"success":true,"data":{"id":5,"ticket_number":"2025"}}`),
+		Duration:       40 * time.Millisecond,
+	}
+	require.NoError(t, svc.Record(ctx, match, exchange))
+
+	upload := exchange
+	upload.RequestHeader = http.Header{"Content-Type": {"multipart/form-data; boundary=x"}}
+	upload.RequestBody = []byte("--x\r\nbinary")
+	require.NoError(t, svc.Record(ctx, match, upload))
+	// The capture is full now.
+	require.NoError(t, svc.Record(ctx, match, exchange))
+	m, err := svc.Match(ctx, http.MethodPost, "/api/v1/tickets", "token:7")
+	require.NoError(t, err)
+	assert.Nil(t, m, "a full capture stops matching")
+
+	requests, err := svc.ListRequests(ctx, rc.ID)
+	require.NoError(t, err)
+	require.Len(t, requests, 2)
+
+	r := requests[0]
+	assert.Equal(t, RedactedValue, r.RequestHeaders["Authorization"])
+	assert.Equal(t, RedactedValue, r.RequestHeaders["X-Hub-Signature"])
+	assert.Equal(t, "Zapier", r.RequestHeaders["User-Agent"])
+	assert.Equal(t, RedactedValue, r.ResponseHeaders["Set-Cookie"])
+	assert.Equal(t, "access_token=%5BREDACTED%5D&dry_run=1", r.Query)
+	assert.JSONEq(t, `{"title":"Printer","customer":{"password":"[REDACTED]","ssn":"[REDACTED]"},"api_token":null}`, r.RequestBody)
+	assert.JSONEq(t, string(exchange.ResponseBody), r.ResponseBody)
+	assert.Equal(t, int64(40), r.DurationMS)
+	assert.False(t, r.Truncated)
+
+	assert.Empty(t, requests[1].RequestBody, "binary bodies are left out")
+	assert.True(t, requests[1].Truncated)
+
+	got, err := svc.GetCapture(ctx, rc.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 2, got.Captured)
+
+	require.NoError(t, svc.DeleteCapture(ctx, rc.ID))
+	_, err = svc.GetRequest(ctx, r.ID)
+	assert.ErrorIs(t, err, ErrCapturedRequestNotFound)
+}
+
+func TestRequestCaptureService_Replay(t *testing.T) {
+	var gotAuth, gotReplay, gotHubSig, gotBody string
+	staging := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotAuth = r.Header.Get("Authorization")
+		gotReplay = r.Header.Get(ReplayHeader)
+		gotHubSig = r.Header.Get("X-Hub-Signature")
+		body, _ := io.ReadAll(r.Body)
+		gotBody = string(body)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		_, _ = w.Write([]byte(`{"success":false,"data":{"id":6,"ticket_number":"2026","token":"new"},"error":"queue required"}`))
+	}))
+	defer staging.Close()
+
+	svc := newRequestCaptureTestService(t, map[string]string{"staging": staging.URL + "/", "down": "http://127.0.0.1:1"})
+	ctx := context.Background()
+	assert.Equal(t, []string{"down", "staging"}, svc.ReplayTargets())
+
+	rc, err := svc.CreateCapture(ctx, &models.RequestCapture{Name: "all"}, 1)
+	require.NoError(t, err)
+	require.NoError(t, svc.Record(ctx, rc, CapturedExchange{
+		Principal: "user:3", Method: http.MethodPost, Path: "/api/v1/tickets", Query: "notify=0",
+		RequestHeader:  http.Header{"Content-Type": {"application/json"}, "X-Hub-Signature": {"sha256=00"}},
+		RequestBody:    []byte(`{"title":"Printer"}`),
+		ResponseHeader: http.Header{"Content-Type": {"application/json"}},
+		StatusCode:     http.StatusCreated,
+		ResponseBody:   []byte(`{"success":true,"data":{"id":5,"ticket_number":"2025","token":"old"}}`),
+	}))
+	requests, err := svc.ListRequests(ctx, rc.ID)
+	require.NoError(t, err)
+	require.Len(t, requests, 1)
+	captured := requests[0]
+
+	_, err = svc.Replay(ctx, captured.ID, ReplayRequest{Target: "production"}, 1)
+	assert.ErrorIs(t, err, ErrReplayTargetUnknown)
+
+	body := `{"title":"Printer","queue_id":2}`
+	replay, err := svc.Replay(ctx, captured.ID, ReplayRequest{
+		Target: "staging", Token: "gf_staging", Body: &body, Ignore: []string{"ticket_number"},
+	}, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer gf_staging", gotAuth)
+	assert.NotEmpty(t, gotReplay)
+	assert.Empty(t, gotHubSig, "redacted headers are not sent")
+	assert.Equal(t, body, gotBody)
+	assert.Equal(t, http.StatusBadRequest, replay.StatusCode)
+
+	diff, err := json.Marshal(replay.Differences)
+	require.NoError(t, err)
+	assert.JSONEq(t, `[
+		{"path":"status","before":201,"after":400},
+		{"path":"data.id","before":5,"after":6},
+		{"path":"error","before":null,"after":"queue required"},
+		{"path":"success","before":true,"after":false}
+	]`, string(diff), "redacted tokens compare equal and ignored fields are skipped")
+
+	failed, err := svc.Replay(ctx, captured.ID, ReplayRequest{Target: "down"}, 1)
+	require.NoError(t, err)
+	assert.Zero(t, failed.StatusCode)
+	assert.NotEmpty(t, failed.Error)
+
+	replays, err := svc.ListReplays(ctx, captured.ID)
+	require.NoError(t, err)
+	require.Len(t, replays, 2)
+	assert.Equal(t, failed.ID, replays[0].ID)
+	stored, err := json.Marshal(replays[1].Differences)
+	require.NoError(t, err)
+	assert.JSONEq(t, string(diff), string(stored))
+}
+
+func TestRequestCaptureService_PurgeExpired(t *testing.T) {
+	svc := newRequestCaptureTestService(t, nil)
+	ctx := context.Background()
+
+	rc, err := svc.CreateCapture(ctx, &models.RequestCapture{Name: "old"}, 1)
+	require.NoError(t, err)
+	require.NoError(t, svc.Record(ctx, rc, CapturedExchange{Principal: "user:1", Method: http.MethodGet, Path: "/api/v1/queues",
+		RequestHeader: http.Header{}, ResponseHeader: http.Header{}, StatusCode: http.StatusOK}))
+
+	svc.now = func() time.Time { return time.Now().Add(DefaultCaptureRetention + 2*time.Hour) }
+	n, err := svc.PurgeExpired(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+	_, err = svc.GetCapture(ctx, rc.ID)
+	assert.ErrorIs(t, err, ErrRequestCaptureNotFound)
+}
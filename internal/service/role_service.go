@@ -0,0 +1,357 @@
+package service
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/repository"
+	"github.com/goatkit/goatflow/internal/services"
+)
+
+// Errors returned by RoleService.
+var (
+	ErrRoleNotFound          = repository.ErrRoleNotFound
+	ErrRoleNameRequired      = errors.New("role name is required")
+	ErrRoleNameTaken         = errors.New("a role with this name already exists")
+	ErrRoleInvalidPermission = fmt.Errorf("permissions must be one of %s", strings.Join(models.PermissionTypes, ", "))
+	ErrRoleGroupNotFound     = errors.New("group_id must be an existing group")
+	ErrRoleUserNotFound      = errors.New("user_ids must be existing agents")
+	ErrRoleAgentNotFound     = errors.New("agent not found")
+	ErrRoleRoleNotFound      = errors.New("role_ids must be existing roles")
+)
+
+// RoleInput is the editable part of a role. A nil Permissions leaves the
+// role's group permissions unchanged on update.
+type RoleInput struct {
+	Name        string                         `json:"name"`
+	Comments    string                         `json:"comments"`
+	ValidID     int                            `json:"valid_id"`
+	Permissions []*models.RoleGroupPermissions `json:"permissions"`
+}
+
+// RoleDetails is a role with its members and group permissions.
+type RoleDetails struct {
+	*models.DBRole
+	UserIDs     []int                          `json:"user_ids"`
+	Permissions []*models.RoleGroupPermissions `json:"permissions"`
+}
+
+// RoleService manages OTRS-style roles: bundles of group permissions that
+// agents receive by being assigned the role. PermissionService grants an
+// agent the union of their direct group permissions and those of their
+// valid roles, so every change here invalidates the permission cache.
+type RoleService struct {
+	db   *sql.DB
+	repo *repository.DBRoleRepository
+}
+
+// NewRoleService creates a role service.
+func NewRoleService(db *sql.DB) *RoleService {
+	return &RoleService{db: db, repo: repository.NewDBRoleRepository(db)}
+}
+
+// List returns the roles ordered by name, leaving out invalid ones unless
+// includeInvalid is set.
+func (s *RoleService) List(includeInvalid bool) ([]*models.DBRole, error) {
+	roles, err := s.repo.List()
+	if err != nil {
+		return nil, err
+	}
+	result := []*models.DBRole{}
+	for _, r := range roles {
+		if includeInvalid || r.IsValid() {
+			result = append(result, r)
+		}
+	}
+	return result, nil
+}
+
+// Get returns a role with its members and group permissions.
+func (s *RoleService) Get(id int) (*RoleDetails, error) {
+	role, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	users, err := s.repo.ListRoleUsers(id)
+	if err != nil {
+		return nil, err
+	}
+	perms, err := s.repo.ListRolePermissions(id)
+	if err != nil {
+		return nil, err
+	}
+	if users == nil {
+		users = []int{}
+	}
+	sort.Ints(users)
+	return &RoleDetails{DBRole: role, UserIDs: users, Permissions: perms}, nil
+}
+
+// Create adds a role, valid unless input.ValidID says otherwise.
+func (s *RoleService) Create(input RoleInput, userID int) (*RoleDetails, error) {
+	perms, err := s.validate(0, &input)
+	if err != nil {
+		return nil, err
+	}
+	role := &models.DBRole{
+		Name:     input.Name,
+		Comments: input.Comments,
+		ValidID:  input.ValidID,
+		CreateBy: userID,
+		ChangeBy: userID,
+	}
+	if err := s.repo.Create(role); err != nil {
+		return nil, err
+	}
+	if len(perms) > 0 {
+		if err := s.repo.SetRolePermissions(role.ID, perms, userID); err != nil {
+			return nil, err
+		}
+	}
+	return s.Get(role.ID)
+}
+
+// Update changes a role's name, comments and validity, and its group
+// permissions when input.Permissions is set.
+func (s *RoleService) Update(id int, input RoleInput, userID int) (*RoleDetails, error) {
+	role, err := s.repo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	perms, err := s.validate(id, &input)
+	if err != nil {
+		return nil, err
+	}
+	role.Name = input.Name
+	role.Comments = input.Comments
+	role.ValidID = input.ValidID
+	role.ChangeBy = userID
+	if err := s.repo.Update(role); err != nil {
+		return nil, err
+	}
+	if input.Permissions != nil {
+		if err := s.repo.SetRolePermissions(id, perms, userID); err != nil {
+			return nil, err
+		}
+	}
+	services.InvalidateAllPermissions()
+	return s.Get(id)
+}
+
+// Delete invalidates a role (valid_id = 2), as OTRS does. Its members and
+// group permissions are kept but no longer grant anything.
+func (s *RoleService) Delete(id, userID int) error {
+	role, err := s.repo.GetByID(id)
+	if err != nil {
+		return err
+	}
+	role.ValidID = 2
+	role.ChangeBy = userID
+	if err := s.repo.Update(role); err != nil {
+		return err
+	}
+	services.InvalidateAllPermissions()
+	return nil
+}
+
+// SetPermissions replaces the group permissions a role grants.
+func (s *RoleService) SetPermissions(id int, perms []*models.RoleGroupPermissions, userID int) (*RoleDetails, error) {
+	if _, err := s.repo.GetByID(id); err != nil {
+		return nil, err
+	}
+	perms, err := s.normalizePermissions(perms)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.SetRolePermissions(id, perms, userID); err != nil {
+		return nil, err
+	}
+	services.InvalidateAllPermissions()
+	return s.Get(id)
+}
+
+// SetUsers replaces the agents assigned to a role.
+func (s *RoleService) SetUsers(id int, userIDs []int, userID int) (*RoleDetails, error) {
+	if _, err := s.repo.GetByID(id); err != nil {
+		return nil, err
+	}
+	userIDs = uniqueInts(userIDs)
+	ok, err := s.allExist("users", userIDs)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrRoleUserNotFound
+	}
+	previous, err := s.repo.ListRoleUsers(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.SetRoleUsers(id, userIDs, userID); err != nil {
+		return nil, err
+	}
+	for _, u := range uniqueInts(append(previous, userIDs...)) {
+		services.InvalidateUserPermissions(u)
+	}
+	return s.Get(id)
+}
+
+// UserRoles returns the roles assigned to an agent, invalid ones included.
+func (s *RoleService) UserRoles(userID int) ([]*models.DBRole, error) {
+	roles, err := s.repo.ListUserRoles(userID)
+	if err != nil {
+		return nil, err
+	}
+	if roles == nil {
+		roles = []*models.DBRole{}
+	}
+	return roles, nil
+}
+
+// SetUserRoles replaces the roles assigned to an agent.
+func (s *RoleService) SetUserRoles(userID int, roleIDs []int, changedBy int) ([]*models.DBRole, error) {
+	ok, err := s.allExist("users", []int{userID})
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrRoleAgentNotFound
+	}
+	roleIDs = uniqueInts(roleIDs)
+	ok, err = s.allExist("roles", roleIDs)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrRoleRoleNotFound
+	}
+	if err := s.repo.SetUserRoles(userID, roleIDs, changedBy); err != nil {
+		return nil, err
+	}
+	services.InvalidateUserPermissions(userID)
+	return s.UserRoles(userID)
+}
+
+// EffectivePermissions returns the group permissions an agent holds, from
+// direct assignments and valid roles, with the source of each.
+func (s *RoleService) EffectivePermissions(userID int) ([]*models.EffectiveGroupPermission, error) {
+	ok, err := s.allExist("users", []int{userID})
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrRoleAgentNotFound
+	}
+	return s.repo.ListEffectivePermissions(userID)
+}
+
+// validate checks and normalizes input for the role with the given ID (0
+// for a new role) and returns its normalized permissions.
+func (s *RoleService) validate(id int, input *RoleInput) ([]*models.RoleGroupPermissions, error) {
+	input.Name = strings.TrimSpace(input.Name)
+	input.Comments = strings.TrimSpace(input.Comments)
+	if input.Name == "" {
+		return nil, ErrRoleNameRequired
+	}
+	if input.ValidID == 0 {
+		input.ValidID = 1
+	}
+	existing, err := s.repo.GetByName(input.Name)
+	switch {
+	case err == nil && existing.ID != id:
+		return nil, ErrRoleNameTaken
+	case err != nil && !errors.Is(err, ErrRoleNotFound):
+		return nil, err
+	}
+	return s.normalizePermissions(input.Permissions)
+}
+
+// normalizePermissions checks groups and permission keys and merges
+// entries for the same group, dropping duplicate keys.
+func (s *RoleService) normalizePermissions(perms []*models.RoleGroupPermissions) ([]*models.RoleGroupPermissions, error) {
+	byGroup := map[int]map[string]bool{}
+	var groupIDs []int
+	for _, p := range perms {
+		if p == nil {
+			continue
+		}
+		if byGroup[p.GroupID] == nil {
+			byGroup[p.GroupID] = map[string]bool{}
+			groupIDs = append(groupIDs, p.GroupID)
+		}
+		for _, key := range p.Permissions {
+			if !isPermissionType(key) {
+				return nil, ErrRoleInvalidPermission
+			}
+			byGroup[p.GroupID][key] = true
+		}
+	}
+	ok, err := s.allExist("groups", groupIDs)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrRoleGroupNotFound
+	}
+
+	sort.Ints(groupIDs)
+	result := make([]*models.RoleGroupPermissions, 0, len(groupIDs))
+	for _, groupID := range groupIDs {
+		entry := &models.RoleGroupPermissions{GroupID: groupID, Permissions: []string{}}
+		for _, key := range models.PermissionTypes {
+			if byGroup[groupID][key] {
+				entry.Permissions = append(entry.Permissions, key)
+			}
+		}
+		if len(entry.Permissions) > 0 {
+			result = append(result, entry)
+		}
+	}
+	return result, nil
+}
+
+// allExist reports whether every ID is a row of table, one of users, roles
+// or groups.
+func (s *RoleService) allExist(table string, ids []int) (bool, error) {
+	ids = uniqueInts(ids)
+	if len(ids) == 0 {
+		return true, nil
+	}
+	args := make([]interface{}, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	query := fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE id IN (?%s)", table, strings.Repeat(", ?", len(ids)-1))
+	var n int
+	if err := s.db.QueryRow(database.ConvertPlaceholders(query), args...).Scan(&n); err != nil {
+		return false, fmt.Errorf("check %s: %w", table, err)
+	}
+	return n == len(ids), nil
+}
+
+func isPermissionType(key string) bool {
+	for _, t := range models.PermissionTypes {
+		if key == t {
+			return true
+		}
+	}
+	return false
+}
+
+func uniqueInts(ids []int) []int {
+	seen := make(map[int]bool, len(ids))
+	result := make([]int, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			result = append(result, id)
+		}
+	}
+	sort.Ints(result)
+	return result
+}
@@ -0,0 +1,168 @@
+package service
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/services"
+	"github.com/goatkit/goatflow/internal/testutil"
+)
+
+func newRoleTestService(t *testing.T) (*RoleService, *sql.DB) {
+	t.Helper()
+	db := testutil.MigratedDB(t)
+	// Queue 1 belongs to the seeded users group (1), queue 2 to support and
+	// queue 3 to billing.
+	for _, stmt := range []string{
+		`INSERT INTO users (id, login, pw, first_name, last_name, valid_id, create_time, create_by, change_time, change_by) VALUES
+			(1, 'root@localhost', 'x', 'Admin', 'OTRS', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1),
+			(2, 'agent2', 'x', 'Agent', 'Two', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1),
+			(3, 'agent3', 'x', 'Agent', 'Three', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+		`INSERT INTO groups (id, name, valid_id, create_time, create_by, change_time, change_by) VALUES
+			(20, 'support', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1),
+			(30, 'billing', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+		`UPDATE queue SET group_id = 20 WHERE id = 2`,
+		`UPDATE queue SET group_id = 30 WHERE id = 3`,
+		`INSERT INTO group_user (user_id, group_id, permission_key, create_time, create_by, change_time, change_by) VALUES
+			(2, 1, 'rw', CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1),
+			(2, 20, 'ro', CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+	} {
+		_, err := db.Exec(stmt)
+		require.NoError(t, err, stmt)
+	}
+	return NewRoleService(db), db
+}
+
+func TestRoleService_CreateAndUpdate(t *testing.T) {
+	svc, _ := newRoleTestService(t)
+
+	role, err := svc.Create(RoleInput{
+		Name: " Support L1 ",
+		Permissions: []*models.RoleGroupPermissions{
+			{GroupID: 20, Permissions: []string{"rw", "ro", "ro"}},
+			{GroupID: 1, Permissions: []string{"note"}},
+			{GroupID: 20, Permissions: []string{"create"}},
+		},
+	}, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "Support L1", role.Name)
+	assert.Equal(t, 1, role.ValidID)
+	assert.Equal(t, []int{}, role.UserIDs)
+	assert.Equal(t, []*models.RoleGroupPermissions{
+		{GroupID: 20, GroupName: "support", Permissions: []string{"create", "ro", "rw"}},
+		{GroupID: 1, GroupName: "users", Permissions: []string{"note"}},
+	}, role.Permissions)
+
+	_, err = svc.Create(RoleInput{Name: "Support L1"}, 1)
+	assert.ErrorIs(t, err, ErrRoleNameTaken)
+	_, err = svc.Create(RoleInput{Name: "  "}, 1)
+	assert.ErrorIs(t, err, ErrRoleNameRequired)
+	_, err = svc.Create(RoleInput{Name: "Bad", Permissions: []*models.RoleGroupPermissions{{GroupID: 1, Permissions: []string{"admin"}}}}, 1)
+	assert.ErrorIs(t, err, ErrRoleInvalidPermission)
+	_, err = svc.Create(RoleInput{Name: "Bad", Permissions: []*models.RoleGroupPermissions{{GroupID: 99, Permissions: []string{"ro"}}}}, 1)
+	assert.ErrorIs(t, err, ErrRoleGroupNotFound)
+
+	// Renaming to its own name is fine; nil permissions are kept.
+	updated, err := svc.Update(role.ID, RoleInput{Name: "Support L1", Comments: "first line"}, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "first line", updated.Comments)
+	assert.Len(t, updated.Permissions, 2)
+
+	updated, err = svc.Update(role.ID, RoleInput{Name: "Support L1", Permissions: []*models.RoleGroupPermissions{}}, 1)
+	require.NoError(t, err)
+	assert.Empty(t, updated.Permissions)
+
+	_, err = svc.Update(999, RoleInput{Name: "Ghost"}, 1)
+	assert.ErrorIs(t, err, ErrRoleNotFound)
+}
+
+func TestRoleService_UsersAndEffectivePermissions(t *testing.T) {
+	svc, db := newRoleTestService(t)
+	perms := services.NewPermissionService(db)
+
+	billing, err := svc.Create(RoleInput{Name: "Billing", Permissions: []*models.RoleGroupPermissions{
+		{GroupID: 30, Permissions: []string{"rw"}},
+		{GroupID: 20, Permissions: []string{"ro"}},
+	}}, 1)
+	require.NoError(t, err)
+
+	ok, err := perms.CanWriteQueue(2, 3)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	_, err = svc.SetUsers(billing.ID, []int{2, 99}, 1)
+	assert.ErrorIs(t, err, ErrRoleUserNotFound)
+	role, err := svc.SetUsers(billing.ID, []int{3, 2, 2}, 1)
+	require.NoError(t, err)
+	assert.Equal(t, []int{2, 3}, role.UserIDs)
+
+	ok, err = perms.CanWriteQueue(2, 3)
+	require.NoError(t, err)
+	assert.True(t, ok, "role grants rw on billing")
+
+	effective, err := svc.EffectivePermissions(2)
+	require.NoError(t, err)
+	assert.Equal(t, []*models.EffectiveGroupPermission{
+		{GroupID: 30, GroupName: "billing", Permission: "rw", Roles: []string{"Billing"}},
+		{GroupID: 20, GroupName: "support", Permission: "ro", Direct: true, Roles: []string{"Billing"}},
+		{GroupID: 1, GroupName: "users", Permission: "rw", Direct: true},
+	}, effective)
+
+	// Invalid roles grant nothing.
+	require.NoError(t, svc.Delete(billing.ID, 1))
+	ok, err = perms.CanWriteQueue(2, 3)
+	require.NoError(t, err)
+	assert.False(t, ok)
+	effective, err = svc.EffectivePermissions(3)
+	require.NoError(t, err)
+	assert.Empty(t, effective)
+
+	_, err = svc.EffectivePermissions(99)
+	assert.ErrorIs(t, err, ErrRoleAgentNotFound)
+}
+
+func TestRoleService_SetUserRolesInvalidatesCache(t *testing.T) {
+	svc, db := newRoleTestService(t)
+	perms := services.NewPermissionService(db)
+	services.EnablePermissionCache(services.PermissionCacheOptions{})
+	t.Cleanup(services.DisablePermissionCache)
+
+	support, err := svc.Create(RoleInput{Name: "Support", Permissions: []*models.RoleGroupPermissions{
+		{GroupID: 20, Permissions: []string{"rw"}},
+	}}, 1)
+	require.NoError(t, err)
+
+	ok, err := perms.CanWriteQueue(1, 2)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	roles, err := svc.SetUserRoles(1, []int{support.ID}, 1)
+	require.NoError(t, err)
+	require.Len(t, roles, 1)
+	assert.Equal(t, "Support", roles[0].Name)
+	ok, err = perms.CanWriteQueue(1, 2)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	_, err = svc.SetPermissions(support.ID, []*models.RoleGroupPermissions{{GroupID: 20, Permissions: []string{"ro"}}}, 1)
+	require.NoError(t, err)
+	ok, err = perms.CanWriteQueue(1, 2)
+	require.NoError(t, err)
+	assert.False(t, ok)
+	ok, err = perms.CanReadQueue(1, 2)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	_, err = svc.SetUserRoles(1, []int{support.ID, 42}, 1)
+	assert.ErrorIs(t, err, ErrRoleRoleNotFound)
+	roles, err = svc.SetUserRoles(1, nil, 1)
+	require.NoError(t, err)
+	assert.Empty(t, roles)
+	ok, err = perms.CanReadQueue(1, 2)
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
@@ -7,9 +7,17 @@ import (
 	"time"
 
 	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/search"
 	"github.com/goatkit/goatflow/internal/zinc"
 )
 
+// ticketsIndex is the index of live tickets. Archived tickets are kept in
+// its archive partition (see search.IndexName), which searches skip.
+const ticketsIndex = "tickets"
+
+// ticketPartitions are the live and the archive partition of ticketsIndex.
+var ticketPartitions = []string{search.IndexName(ticketsIndex, false), search.IndexName(ticketsIndex, true)}
+
 // SearchService handles search operations for tickets.
 type SearchService struct {
 	client        zinc.Client
@@ -30,20 +38,22 @@ func NewSearchService(client zinc.Client) *SearchService {
 		nextHistoryID: 1,
 	}
 
-	// Initialize tickets index
+	// Initialize tickets index and its archive partition
 	service.initializeIndex(context.Background())
 
 	return service
 }
 
-// initializeIndex creates the tickets index if it doesn't exist.
+// initializeIndex creates the tickets index partitions that don't exist.
 func (s *SearchService) initializeIndex(ctx context.Context) error {
-	exists, err := s.client.IndexExists(ctx, "tickets")
-	if err != nil {
-		return err
-	}
-
-	if !exists {
+	for _, index := range ticketPartitions {
+		exists, err := s.client.IndexExists(ctx, index)
+		if err != nil {
+			return err
+		}
+		if exists {
+			continue
+		}
 		mapping := map[string]interface{}{
 			"properties": map[string]interface{}{
 				"ticket_number": map[string]interface{}{
@@ -87,21 +97,34 @@ func (s *SearchService) initializeIndex(ctx context.Context) error {
 			},
 		}
 
-		return s.client.CreateIndex(ctx, "tickets", mapping)
+		if err := s.client.CreateIndex(ctx, index, mapping); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
 
-// IndexTicket indexes a single ticket.
+// IndexTicket indexes a single ticket into the partition matching its
+// archive state, removing it from the other one.
 func (s *SearchService) IndexTicket(ctx context.Context, ticket *models.Ticket) error {
 	doc := s.mapTicketToSearchDocument(ticket)
-	return s.client.IndexDocument(ctx, "tickets", doc.ID, doc)
+	index := ticketPartition(doc)
+	if err := s.client.IndexDocument(ctx, index, doc.ID, doc); err != nil {
+		return err
+	}
+	_, err := s.removeTicket(ctx, doc.ID, index)
+	return err
 }
 
-// UpdateTicketInIndex updates a ticket in the search index.
+// UpdateTicketInIndex updates a ticket in the search index. A ticket that
+// was archived or restored since it was indexed is indexed again instead.
 func (s *SearchService) UpdateTicketInIndex(ctx context.Context, ticket *models.Ticket) error {
 	doc := s.mapTicketToSearchDocument(ticket)
+	index := ticketPartition(doc)
+	if _, err := s.client.GetDocument(ctx, index, doc.ID); err != nil {
+		return s.IndexTicket(ctx, ticket)
+	}
 
 	updates := map[string]interface{}{
 		"title":      doc.Title,
@@ -112,21 +135,64 @@ func (s *SearchService) UpdateTicketInIndex(ctx context.Context, ticket *models.
 		"updated_at": doc.UpdatedAt,
 	}
 
-	return s.client.UpdateDocument(ctx, "tickets", doc.ID, updates)
+	return s.client.UpdateDocument(ctx, index, doc.ID, updates)
 }
 
-// DeleteTicketFromIndex removes a ticket from the search index.
+// DeleteTicketFromIndex removes a ticket from the search index, whichever
+// partition it is in.
 func (s *SearchService) DeleteTicketFromIndex(ctx context.Context, ticketNumber string) error {
-	return s.client.DeleteDocument(ctx, "tickets", ticketNumber)
+	removed, err := s.removeTicket(ctx, ticketNumber, "")
+	if err != nil {
+		return err
+	}
+	if !removed {
+		return fmt.Errorf("ticket %s is not indexed", ticketNumber)
+	}
+	return nil
 }
 
-// BulkIndexTickets indexes multiple tickets at once.
+// removeTicket deletes a ticket document from every partition but keep and
+// reports whether there was one.
+func (s *SearchService) removeTicket(ctx context.Context, id, keep string) (bool, error) {
+	removed := false
+	for _, index := range ticketPartitions {
+		if index == keep {
+			continue
+		}
+		if _, err := s.client.GetDocument(ctx, index, id); err != nil {
+			continue
+		}
+		if err := s.client.DeleteDocument(ctx, index, id); err != nil {
+			return removed, err
+		}
+		removed = true
+	}
+	return removed, nil
+}
+
+// BulkIndexTickets indexes multiple tickets at once, each into the
+// partition matching its archive state.
 func (s *SearchService) BulkIndexTickets(ctx context.Context, tickets []models.Ticket) error {
-	docs := make([]interface{}, len(tickets))
-	for i, ticket := range tickets {
-		docs[i] = s.mapTicketToSearchDocument(&ticket)
+	docs := make(map[string][]interface{}, len(ticketPartitions))
+	for i := range tickets {
+		doc := s.mapTicketToSearchDocument(&tickets[i])
+		index := ticketPartition(doc)
+		docs[index] = append(docs[index], doc)
+	}
+	for _, index := range ticketPartitions {
+		if len(docs[index]) == 0 {
+			continue
+		}
+		if err := s.client.BulkIndex(ctx, index, docs[index]); err != nil {
+			return err
+		}
 	}
-	return s.client.BulkIndex(ctx, "tickets", docs)
+	return nil
+}
+
+// ticketPartition returns the index partition of a ticket document.
+func ticketPartition(doc *models.TicketSearchDocument) string {
+	return search.IndexName(ticketsIndex, doc.Archived)
 }
 
 // SearchTickets performs a ticket search.
@@ -139,7 +205,7 @@ func (s *SearchService) SearchTickets(ctx context.Context, request *models.Searc
 		request.PageSize = 20
 	}
 
-	return s.client.Search(ctx, "tickets", request)
+	return s.client.Search(ctx, ticketsIndex, request)
 }
 
 // SearchWithFilter performs an advanced search with filters.
@@ -177,12 +243,12 @@ func (s *SearchService) SearchWithFilter(ctx context.Context, filter *models.Sea
 		request.DateTo = filter.CreatedBefore
 	}
 
-	return s.client.Search(ctx, "tickets", request)
+	return s.client.Search(ctx, ticketsIndex, request)
 }
 
 // GetSearchSuggestions provides search suggestions.
 func (s *SearchService) GetSearchSuggestions(ctx context.Context, text string) ([]string, error) {
-	return s.client.Suggest(ctx, "tickets", text, "title")
+	return s.client.Suggest(ctx, ticketsIndex, text, "title")
 }
 
 // SaveSearch saves a search query for later use.
@@ -387,8 +453,10 @@ func (s *SearchService) ReindexAllTickets(ctx context.Context, fetcher func() ([
 
 	stats.Total = len(tickets)
 
-	// Delete and recreate index
-	s.client.DeleteIndex(ctx, "tickets")
+	// Delete and recreate both partitions
+	for _, index := range ticketPartitions {
+		s.client.DeleteIndex(ctx, index)
+	}
 	s.initializeIndex(ctx)
 
 	// Bulk index in batches
@@ -425,6 +493,7 @@ func (s *SearchService) mapTicketToSearchDocument(ticket *models.Ticket) *models
 		Queue:        s.mapQueueIDToName(ticket.QueueID),
 		CreatedAt:    ticket.CreateTime,
 		UpdatedAt:    ticket.ChangeTime,
+		Archived:     ticket.IsArchived(),
 	}
 
 	// Add customer info if available
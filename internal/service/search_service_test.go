@@ -137,6 +137,37 @@ func TestSearchService(t *testing.T) {
 		assert.Equal(t, "closed", doc["status"])
 	})
 
+	t.Run("ArchivedTicketsUseTheArchivePartition", func(t *testing.T) {
+		client := zinc.NewMockZincClient()
+		service := NewSearchService(client)
+
+		ticket := &models.Ticket{ID: 301, TicketNumber: "TICKET-301", Title: "Old printer issue", ArchiveFlag: 1}
+		require.NoError(t, service.IndexTicket(ctx, ticket))
+		_, err := client.GetDocument(ctx, "tickets_archive", "TICKET-301")
+		require.NoError(t, err)
+		_, err = client.GetDocument(ctx, "tickets", "TICKET-301")
+		assert.Error(t, err, "an archived ticket should not be in the live partition")
+
+		ticket.ArchiveFlag = 0
+		require.NoError(t, service.UpdateTicketInIndex(ctx, ticket))
+		_, err = client.GetDocument(ctx, "tickets", "TICKET-301")
+		require.NoError(t, err)
+		_, err = client.GetDocument(ctx, "tickets_archive", "TICKET-301")
+		assert.Error(t, err, "a restored ticket should leave the archive partition")
+
+		require.NoError(t, service.BulkIndexTickets(ctx, []models.Ticket{
+			{ID: 302, TicketNumber: "TICKET-302", Title: "Live"},
+			{ID: 303, TicketNumber: "TICKET-303", Title: "Archived", ArchiveFlag: 1},
+		}))
+		_, err = client.GetDocument(ctx, "tickets", "TICKET-302")
+		require.NoError(t, err)
+		_, err = client.GetDocument(ctx, "tickets_archive", "TICKET-303")
+		require.NoError(t, err)
+
+		require.NoError(t, service.DeleteTicketFromIndex(ctx, "TICKET-303"))
+		assert.Error(t, service.DeleteTicketFromIndex(ctx, "TICKET-303"))
+	})
+
 	t.Run("DeleteTicketFromIndex", func(t *testing.T) {
 		client := zinc.NewMockZincClient()
 		service := NewSearchService(client)
@@ -10,6 +10,7 @@ import (
 	"github.com/goatkit/goatflow/internal/database"
 	"github.com/goatkit/goatflow/internal/models"
 	"github.com/goatkit/goatflow/internal/repository"
+	"github.com/goatkit/goatflow/internal/storage"
 )
 
 // This is for development/testing with in-memory repository.
@@ -240,6 +241,7 @@ func (s *SimpleTicketService) GetMessages(ticketID uint) ([]*SimpleTicketMessage
 		if err != nil {
 			continue
 		}
+		body = storage.DecodeText(body)
 
 		// Determine author type based on sender_type_id
 		authorType := "System"
@@ -322,6 +324,7 @@ func (s *SimpleTicketService) GetMessages(ticketID uint) ([]*SimpleTicketMessage
 				if err != nil {
 					continue
 				}
+				content = storage.DecodeBytes(content)
 
 				// Parse size
 				size, _ := strconv.ParseInt(contentSize, 10, 64)
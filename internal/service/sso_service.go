@@ -0,0 +1,556 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/goatkit/goatflow/internal/auth"
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/repository"
+	"github.com/goatkit/goatflow/internal/services"
+)
+
+// Errors returned by SSOService.
+var (
+	ErrSSOProviderNotFound = errors.New("sso provider not found")
+	ErrSSONameRequired     = errors.New("sso provider name is required")
+	ErrSSONameExists       = errors.New("an sso provider with this name already exists")
+	ErrSSOInvalidProtocol  = errors.New("protocol must be one of oidc, saml")
+	ErrSSOInvalidFrontend  = errors.New("frontend must be one of agent, customer")
+	ErrSSOIncompleteConfig = errors.New("sso provider configuration is incomplete")
+	ErrSSOInvalidMapping   = errors.New("group mappings need a value, a valid group and a permission")
+	ErrSSONoLogin          = errors.New("the identity provider did not send a username")
+	ErrSSONoSubject        = errors.New("the identity provider did not send a subject")
+	ErrSSOUnknownAccount   = errors.New("no account exists for this user and automatic provisioning is off")
+	ErrSSOAccountDisabled  = errors.New("the account for this user is disabled")
+	ErrSSOAccountNotLinked = errors.New("an account with this username exists but is not linked to this sign-in provider; ask an administrator to link it")
+	ErrSSOAccountNotFound  = errors.New("account not found")
+	ErrSSOSubjectLinked    = errors.New("this identity is already linked to an account")
+	ErrSSOLinkNotFound     = errors.New("sso link not found")
+)
+
+// ssoSystemUserID is recorded as creator of provisioned accounts.
+const ssoSystemUserID = 1
+
+// SSOService manages single sign-on providers and turns identities asserted
+// by them into GoatFlow accounts. Accounts are found by the provider's
+// subject through the links in sso_identity: unknown users are created and
+// linked on first login when the provider allows it, existing accounts are
+// only linked by an admin, and group mappings are applied on every login.
+type SSOService struct {
+	db   *sql.DB
+	repo *repository.SSORepository
+	now  func() time.Time
+}
+
+// NewSSOService creates an SSO service.
+func NewSSOService(db *sql.DB) *SSOService {
+	return &SSOService{db: db, repo: repository.NewSSORepository(db), now: time.Now}
+}
+
+// ListProviders returns every configured provider.
+func (s *SSOService) ListProviders(ctx context.Context) ([]models.SSOProvider, error) {
+	return s.repo.List(ctx)
+}
+
+// EnabledProviders returns the providers offered on a frontend's login page.
+func (s *SSOService) EnabledProviders(ctx context.Context, frontend models.SSOFrontend) ([]models.SSOProvider, error) {
+	return s.repo.ListEnabled(ctx, frontend)
+}
+
+// GetProvider returns a provider or ErrSSOProviderNotFound.
+func (s *SSOService) GetProvider(ctx context.Context, id int) (*models.SSOProvider, error) {
+	p, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return nil, ErrSSOProviderNotFound
+	}
+	return p, nil
+}
+
+// CreateProvider validates and stores a new provider.
+func (s *SSOService) CreateProvider(ctx context.Context, p *models.SSOProvider) error {
+	if p.ValidID == 0 {
+		p.ValidID = 1
+	}
+	if err := s.validate(ctx, p); err != nil {
+		return err
+	}
+	id, err := s.repo.Create(ctx, p)
+	if err != nil {
+		return err
+	}
+	p.ID = id
+	return nil
+}
+
+// UpdateProvider validates and stores changes. A client secret left as
+// models.SSOSecretPlaceholder keeps the stored secret.
+func (s *SSOService) UpdateProvider(ctx context.Context, p *models.SSOProvider, userID int) error {
+	existing, err := s.GetProvider(ctx, p.ID)
+	if err != nil {
+		return err
+	}
+	if p.Config.ClientSecret == models.SSOSecretPlaceholder {
+		p.Config.ClientSecret = existing.Config.ClientSecret
+	}
+	if p.ValidID == 0 {
+		p.ValidID = existing.ValidID
+	}
+	if err := s.validate(ctx, p); err != nil {
+		return err
+	}
+	if err := s.repo.Update(ctx, p, userID); err != nil {
+		if err == sql.ErrNoRows {
+			return ErrSSOProviderNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// DeleteProvider removes a provider.
+func (s *SSOService) DeleteProvider(ctx context.Context, id int) error {
+	if err := s.repo.Delete(ctx, id); err != nil {
+		if err == sql.ErrNoRows {
+			return ErrSSOProviderNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+func (s *SSOService) validate(ctx context.Context, p *models.SSOProvider) error {
+	p.Name = strings.TrimSpace(p.Name)
+	if p.Name == "" {
+		return ErrSSONameRequired
+	}
+	if !p.Protocol.IsValid() {
+		return ErrSSOInvalidProtocol
+	}
+	if !p.Frontend.IsValid() {
+		return ErrSSOInvalidFrontend
+	}
+
+	cfg := &p.Config
+	switch p.Protocol {
+	case models.SSOProtocolOIDC:
+		if cfg.Issuer == "" || cfg.ClientID == "" {
+			return fmt.Errorf("%w: issuer and client ID are required", ErrSSOIncompleteConfig)
+		}
+	case models.SSOProtocolSAML:
+		if cfg.IdPSSOURL == "" || cfg.IdPEntityID == "" || cfg.IdPCertificate == "" {
+			return fmt.Errorf("%w: IdP SSO URL, entity ID and certificate are required", ErrSSOIncompleteConfig)
+		}
+		if _, err := auth.ParseCertificates(cfg.IdPCertificate); err != nil {
+			return fmt.Errorf("%w: %v", ErrSSOIncompleteConfig, err)
+		}
+	}
+
+	for _, m := range p.GroupMappings {
+		if strings.TrimSpace(m.Value) == "" || m.GroupID <= 0 || !validSSOPermission(p.Frontend, m.Permission) {
+			return ErrSSOInvalidMapping
+		}
+		var count int
+		if err := s.db.QueryRowContext(ctx, database.ConvertPlaceholders(
+			"SELECT COUNT(*) FROM groups WHERE id = ? AND valid_id = 1"), m.GroupID).Scan(&count); err != nil {
+			return fmt.Errorf("check group: %w", err)
+		}
+		if count == 0 {
+			return ErrSSOInvalidMapping
+		}
+	}
+
+	exists, err := s.repo.NameExists(ctx, p.Name, p.ID)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return ErrSSONameExists
+	}
+	return nil
+}
+
+// validSSOPermission reports whether perm can be granted on the frontend:
+// agents take any permission type, customers only ro and rw.
+func validSSOPermission(frontend models.SSOFrontend, perm string) bool {
+	if frontend == models.SSOFrontendCustomer {
+		return perm == "ro" || perm == "rw"
+	}
+	for _, t := range models.PermissionTypes {
+		if perm == t {
+			return true
+		}
+	}
+	return false
+}
+
+// IdentityFromClaims maps OIDC claims or SAML attributes to an identity
+// using the provider's claim names. subject is the OIDC sub or SAML NameID.
+// An OIDC email address is only used when the email_verified claim is true:
+// an unverified address may belong to someone else.
+func IdentityFromClaims(p *models.SSOProvider, subject string, claims map[string][]string) (*models.SSOIdentity, error) {
+	subject = strings.TrimSpace(subject)
+	if subject == "" {
+		return nil, ErrSSONoSubject
+	}
+	first := func(name string) string {
+		if name == "" {
+			return ""
+		}
+		for _, v := range claims[name] {
+			if v = strings.TrimSpace(v); v != "" {
+				return v
+			}
+		}
+		return ""
+	}
+	cfg := p.Config
+	id := &models.SSOIdentity{
+		Subject:   subject,
+		Email:     first(defaultString(cfg.EmailClaim, "email")),
+		FirstName: first(defaultString(cfg.FirstNameClaim, "given_name")),
+		LastName:  first(defaultString(cfg.LastNameClaim, "family_name")),
+	}
+	if p.Protocol == models.SSOProtocolOIDC && !strings.EqualFold(first("email_verified"), "true") {
+		id.Email = ""
+	}
+
+	switch {
+	case cfg.UsernameClaim != "":
+		id.Login = first(cfg.UsernameClaim)
+	case p.Protocol == models.SSOProtocolSAML:
+		id.Login = subject
+	default:
+		id.Login = first("preferred_username")
+		if id.Login == "" {
+			id.Login = id.Email
+		}
+	}
+	if id.Login == "" {
+		return nil, ErrSSONoLogin
+	}
+	if id.Email == "" && strings.Contains(id.Login, "@") {
+		id.Email = id.Login
+	}
+	if cfg.GroupsClaim != "" {
+		for _, g := range claims[cfg.GroupsClaim] {
+			if g = strings.TrimSpace(g); g != "" {
+				id.Groups = append(id.Groups, g)
+			}
+		}
+	}
+	return id, nil
+}
+
+func defaultString(v, fallback string) string {
+	if v == "" {
+		return fallback
+	}
+	return v
+}
+
+// ProvisionAgent returns the agent linked to an identity, creating and
+// linking it when the provider allows just-in-time provisioning, and
+// applies group mappings.
+func (s *SSOService) ProvisionAgent(ctx context.Context, p *models.SSOProvider, id *models.SSOIdentity) (int, error) {
+	userID, err := s.repo.LinkedUser(ctx, p.ID, id.Subject)
+	if err != nil {
+		return 0, err
+	}
+	if userID == 0 {
+		if userID, err = s.provisionUnlinked(ctx, p, id, "users", func() (int, error) {
+			return s.createAgent(ctx, id)
+		}); err != nil {
+			return 0, err
+		}
+	}
+
+	var validID int
+	err = s.db.QueryRowContext(ctx, database.ConvertPlaceholders(
+		"SELECT valid_id FROM users WHERE id = ?"), userID).Scan(&validID)
+	switch {
+	case err == sql.ErrNoRows:
+		return 0, ErrSSOUnknownAccount
+	case err != nil:
+		return 0, fmt.Errorf("look up agent: %w", err)
+	case validID != 1:
+		return 0, ErrSSOAccountDisabled
+	}
+
+	if err := s.syncGroups(ctx, p, id, "group_user", userID); err != nil {
+		return 0, err
+	}
+	services.InvalidateUserPermissions(userID)
+	return userID, nil
+}
+
+// ProvisionCustomer returns the login of the customer user linked to an
+// identity, creating and linking the customer user when the provider allows
+// it, and applies group mappings.
+func (s *SSOService) ProvisionCustomer(ctx context.Context, p *models.SSOProvider, id *models.SSOIdentity) (string, error) {
+	userID, err := s.repo.LinkedUser(ctx, p.ID, id.Subject)
+	if err != nil {
+		return "", err
+	}
+	if userID == 0 {
+		if userID, err = s.provisionUnlinked(ctx, p, id, "customer_user", func() (int, error) {
+			return s.createCustomer(ctx, p, id)
+		}); err != nil {
+			return "", err
+		}
+	}
+
+	var login string
+	var validID int
+	err = s.db.QueryRowContext(ctx, database.ConvertPlaceholders(
+		"SELECT login, valid_id FROM customer_user WHERE id = ?"), userID).Scan(&login, &validID)
+	switch {
+	case err == sql.ErrNoRows:
+		return "", ErrSSOUnknownAccount
+	case err != nil:
+		return "", fmt.Errorf("look up customer user: %w", err)
+	case validID != 1:
+		return "", ErrSSOAccountDisabled
+	}
+
+	if err := s.syncGroups(ctx, p, id, "group_customer_user", login); err != nil {
+		return "", err
+	}
+	services.InvalidateCustomerUserPermissions(login)
+	return login, nil
+}
+
+// provisionUnlinked creates and links the account for an identity without
+// a link. An existing account with the identity's login is refused rather
+// than signed in to: the username claim is controlled by the IdP, so
+// matching it would hand the account to anyone who can set the claim.
+func (s *SSOService) provisionUnlinked(ctx context.Context, p *models.SSOProvider, id *models.SSOIdentity, table string, create func() (int, error)) (int, error) {
+	var count int
+	if err := s.db.QueryRowContext(ctx, database.ConvertPlaceholders(
+		"SELECT COUNT(*) FROM "+table+" WHERE login = ?"), id.Login).Scan(&count); err != nil {
+		return 0, fmt.Errorf("look up account: %w", err)
+	}
+	if count > 0 {
+		return 0, ErrSSOAccountNotLinked
+	}
+	if !p.JITProvisioning {
+		return 0, ErrSSOUnknownAccount
+	}
+	userID, err := create()
+	if err != nil {
+		return 0, err
+	}
+	if err := s.repo.CreateLink(ctx, p.ID, id.Subject, userID, ssoSystemUserID); err != nil {
+		return 0, err
+	}
+	return userID, nil
+}
+
+// ListLinks returns the accounts linked to a provider.
+func (s *SSOService) ListLinks(ctx context.Context, providerID int) ([]models.SSOLink, error) {
+	p, err := s.GetProvider(ctx, providerID)
+	if err != nil {
+		return nil, err
+	}
+	return s.repo.ListLinks(ctx, p.ID, ssoAccountTable(p))
+}
+
+// LinkAccount links the existing account login to a provider's subject, so
+// the identity signs in to it. Each subject links to one account.
+func (s *SSOService) LinkAccount(ctx context.Context, providerID int, subject, login string, userID int) (*models.SSOLink, error) {
+	p, err := s.GetProvider(ctx, providerID)
+	if err != nil {
+		return nil, err
+	}
+	subject, login = strings.TrimSpace(subject), strings.TrimSpace(login)
+	if subject == "" {
+		return nil, ErrSSONoSubject
+	}
+	var accountID int
+	err = s.db.QueryRowContext(ctx, database.ConvertPlaceholders(
+		"SELECT id FROM "+ssoAccountTable(p)+" WHERE login = ?"), login).Scan(&accountID)
+	if err == sql.ErrNoRows {
+		return nil, ErrSSOAccountNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("look up account: %w", err)
+	}
+	linked, err := s.repo.LinkedUser(ctx, p.ID, subject)
+	if err != nil {
+		return nil, err
+	}
+	if linked != 0 {
+		return nil, ErrSSOSubjectLinked
+	}
+	if err := s.repo.CreateLink(ctx, p.ID, subject, accountID, userID); err != nil {
+		return nil, err
+	}
+	return &models.SSOLink{ProviderID: p.ID, Subject: subject, UserID: accountID, Login: login,
+		CreateTime: s.now(), CreateBy: userID}, nil
+}
+
+// UnlinkAccount removes the link of a provider's subject. The account is
+// kept; the identity can no longer sign in to it.
+func (s *SSOService) UnlinkAccount(ctx context.Context, providerID int, subject string) error {
+	if err := s.repo.DeleteLink(ctx, providerID, subject); err != nil {
+		if err == sql.ErrNoRows {
+			return ErrSSOLinkNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// ssoAccountTable is the table holding the accounts a provider signs in to.
+func ssoAccountTable(p *models.SSOProvider) string {
+	if p.Frontend == models.SSOFrontendCustomer {
+		return "customer_user"
+	}
+	return "users"
+}
+
+// unusablePassword returns a hash of random bytes, so a provisioned account
+// cannot log in with a password until an admin or the user sets one.
+func unusablePassword() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return auth.NewPasswordHasher().HashPassword(hex.EncodeToString(buf))
+}
+
+func (s *SSOService) createAgent(ctx context.Context, id *models.SSOIdentity) (int, error) {
+	pw, err := unusablePassword()
+	if err != nil {
+		return 0, err
+	}
+	first, last := ssoDisplayName(id)
+	now := s.now()
+	query := database.ConvertPlaceholders(`
+		INSERT INTO users (login, pw, first_name, last_name, valid_id, create_time, create_by, change_time, change_by)
+		VALUES (?, ?, ?, ?, 1, ?, ?, ?, ?)
+		RETURNING id`)
+	userID, err := database.GetAdapter().InsertWithReturning(s.db, query,
+		id.Login, pw, first, last, now, ssoSystemUserID, now, ssoSystemUserID)
+	if err != nil {
+		return 0, fmt.Errorf("provision agent: %w", err)
+	}
+	return int(userID), nil
+}
+
+func (s *SSOService) createCustomer(ctx context.Context, p *models.SSOProvider, id *models.SSOIdentity) (int, error) {
+	pw, err := unusablePassword()
+	if err != nil {
+		return 0, err
+	}
+	customerID := p.Config.DefaultCustomerID
+	if customerID == "" {
+		if at := strings.LastIndex(id.Email, "@"); at >= 0 {
+			customerID = id.Email[at+1:]
+		} else {
+			customerID = id.Login
+		}
+	}
+	first, last := ssoDisplayName(id)
+	now := s.now()
+	query := database.ConvertPlaceholders(`
+		INSERT INTO customer_user (login, email, customer_id, pw, first_name, last_name, valid_id,
+			create_time, create_by, change_time, change_by)
+		VALUES (?, ?, ?, ?, ?, ?, 1, ?, ?, ?, ?)
+		RETURNING id`)
+	userID, err := database.GetAdapter().InsertWithReturning(s.db, query,
+		id.Login, id.Email, customerID, pw, first, last, now, ssoSystemUserID, now, ssoSystemUserID)
+	if err != nil {
+		return 0, fmt.Errorf("provision customer user: %w", err)
+	}
+	return int(userID), nil
+}
+
+// ssoDisplayName falls back to the login for names the IdP did not send;
+// both columns are required.
+func ssoDisplayName(id *models.SSOIdentity) (string, string) {
+	first, last := id.FirstName, id.LastName
+	if first == "" {
+		first = id.Login
+	}
+	if last == "" {
+		last = "-"
+	}
+	return first, last
+}
+
+// syncGroups makes the mapped group permissions match the identity's groups.
+// Permissions that no mapping mentions are left alone, so groups assigned
+// by an admin survive a login.
+func (s *SSOService) syncGroups(ctx context.Context, p *models.SSOProvider, id *models.SSOIdentity, table string, userKey interface{}) error {
+	if err := syncMappedGroups(ctx, s.db, s.now(), p.GroupMappings, id.Groups, table, userKey); err != nil {
+		return fmt.Errorf("sync sso groups: %w", err)
+	}
+	return nil
+}
+
+// syncMappedGroups grants each mapped permission in table (group_user or
+// group_customer_user) when one of values matches the mapping's value and
+// revokes it otherwise. Values are compared case-insensitively.
+func syncMappedGroups(ctx context.Context, db *sql.DB, now time.Time, mappings []models.SSOGroupMapping, values []string, table string, userKey interface{}) error {
+	if len(mappings) == 0 {
+		return nil
+	}
+	type grant struct {
+		groupID int
+		perm    string
+	}
+	want := make(map[grant]bool)
+	for _, m := range mappings {
+		g := grant{m.GroupID, m.Permission}
+		if _, seen := want[g]; !seen {
+			want[g] = false
+		}
+		for _, value := range values {
+			if strings.EqualFold(value, m.Value) {
+				want[g] = true
+			}
+		}
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() //nolint:errcheck // No-op after commit
+
+	for g, granted := range want {
+		var count int
+		if err := tx.QueryRowContext(ctx, database.ConvertPlaceholders(
+			"SELECT COUNT(*) FROM "+table+" WHERE user_id = ? AND group_id = ? AND permission_key = ?"),
+			userKey, g.groupID, g.perm).Scan(&count); err != nil {
+			return err
+		}
+		switch {
+		case granted && count == 0:
+			query := "INSERT INTO group_user (user_id, group_id, permission_key, create_time, create_by, change_time, change_by) VALUES (?, ?, ?, ?, ?, ?, ?)"
+			if table == "group_customer_user" {
+				query = "INSERT INTO group_customer_user (user_id, group_id, permission_key, permission_value, create_time, create_by, change_time, change_by) VALUES (?, ?, ?, 1, ?, ?, ?, ?)"
+			}
+			_, err = tx.ExecContext(ctx, database.ConvertPlaceholders(query), userKey, g.groupID, g.perm, now, ssoSystemUserID, now, ssoSystemUserID)
+		case !granted && count > 0:
+			_, err = tx.ExecContext(ctx, database.ConvertPlaceholders(
+				"DELETE FROM "+table+" WHERE user_id = ? AND group_id = ? AND permission_key = ?"),
+				userKey, g.groupID, g.perm)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,297 @@
+package service
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"database/sql"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/testutil"
+)
+
+func newSSOTestService(t *testing.T) (*SSOService, *sql.DB) {
+	t.Helper()
+	db := testutil.UseMigratedDB(t)
+	for _, stmt := range []string{
+		`INSERT INTO users (id, login, pw, first_name, last_name, valid_id, create_time, create_by, change_time, change_by)
+			VALUES (1, 'root@localhost', 'x', 'Admin', 'OTRS', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1),
+			(2, 'disabled@example.com', 'x', 'Dis', 'Abled', 2, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+		`INSERT INTO groups (id, name, valid_id, create_time, create_by, change_time, change_by)
+			VALUES (20, 'support', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1),
+			(21, 'retired', 2, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+	} {
+		_, err := db.Exec(stmt)
+		require.NoError(t, err, stmt)
+	}
+	return NewSSOService(db), db
+}
+
+func testSSOCertificate(t *testing.T) string {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "idp.example.com"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+}
+
+func testOIDCProvider() *models.SSOProvider {
+	return &models.SSOProvider{
+		Name:     "Company Login",
+		Protocol: models.SSOProtocolOIDC,
+		Frontend: models.SSOFrontendAgent,
+		Config: models.SSOProviderConfig{
+			Issuer: "https://login.example.com", ClientID: "goatflow", ClientSecret: "s3cret",
+			GroupsClaim: "groups",
+		},
+		GroupMappings: []models.SSOGroupMapping{
+			{Value: "helpdesk", GroupID: 20, Permission: "rw"},
+			{Value: "helpdesk-admins", GroupID: 2, Permission: "rw"},
+		},
+		JITProvisioning: true,
+		CreateBy:        1,
+	}
+}
+
+func TestSSOService_ProviderValidation(t *testing.T) {
+	svc, _ := newSSOTestService(t)
+	ctx := context.Background()
+
+	p := testOIDCProvider()
+	p.Config.Issuer = ""
+	assert.ErrorIs(t, svc.CreateProvider(ctx, p), ErrSSOIncompleteConfig)
+
+	p = testOIDCProvider()
+	p.GroupMappings = append(p.GroupMappings, models.SSOGroupMapping{Value: "old", GroupID: 21, Permission: "rw"})
+	assert.ErrorIs(t, svc.CreateProvider(ctx, p), ErrSSOInvalidMapping)
+
+	p = testOIDCProvider()
+	p.Frontend = models.SSOFrontendCustomer
+	p.GroupMappings = []models.SSOGroupMapping{{Value: "x", GroupID: 20, Permission: "owner"}}
+	assert.ErrorIs(t, svc.CreateProvider(ctx, p), ErrSSOInvalidMapping)
+
+	saml := &models.SSOProvider{Name: "ADFS", Protocol: models.SSOProtocolSAML, Frontend: models.SSOFrontendCustomer, CreateBy: 1,
+		Config: models.SSOProviderConfig{IdPSSOURL: "https://adfs.example.com/sso", IdPEntityID: "adfs", IdPCertificate: "garbage"}}
+	assert.ErrorIs(t, svc.CreateProvider(ctx, saml), ErrSSOIncompleteConfig)
+	saml.Config.IdPCertificate = testSSOCertificate(t)
+	require.NoError(t, svc.CreateProvider(ctx, saml))
+
+	p = testOIDCProvider()
+	require.NoError(t, svc.CreateProvider(ctx, p))
+	assert.ErrorIs(t, svc.CreateProvider(ctx, testOIDCProvider()), ErrSSONameExists)
+
+	enabled, err := svc.EnabledProviders(ctx, models.SSOFrontendAgent)
+	require.NoError(t, err)
+	require.Len(t, enabled, 1)
+	assert.Equal(t, "Company Login", enabled[0].Name)
+	assert.Len(t, enabled[0].GroupMappings, 2)
+
+	// The placeholder shown in the form keeps the stored secret.
+	update := enabled[0].Redacted()
+	assert.Equal(t, models.SSOSecretPlaceholder, update.Config.ClientSecret)
+	update.ValidID = 2
+	require.NoError(t, svc.UpdateProvider(ctx, &update, 1))
+	stored, err := svc.GetProvider(ctx, p.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "s3cret", stored.Config.ClientSecret)
+	assert.Equal(t, 2, stored.ValidID)
+
+	enabled, err = svc.EnabledProviders(ctx, models.SSOFrontendAgent)
+	require.NoError(t, err)
+	assert.Empty(t, enabled)
+
+	require.NoError(t, svc.DeleteProvider(ctx, p.ID))
+	_, err = svc.GetProvider(ctx, p.ID)
+	assert.ErrorIs(t, err, ErrSSOProviderNotFound)
+}
+
+func TestIdentityFromClaims(t *testing.T) {
+	oidc := testOIDCProvider()
+
+	id, err := IdentityFromClaims(oidc, "abc123", map[string][]string{
+		"preferred_username": {"jdoe"}, "email": {"jane@example.com"}, "email_verified": {"true"},
+		"given_name": {"Jane"}, "family_name": {"Doe"}, "groups": {"helpdesk", " "},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, &models.SSOIdentity{Subject: "abc123", Login: "jdoe", Email: "jane@example.com",
+		FirstName: "Jane", LastName: "Doe", Groups: []string{"helpdesk"}}, id)
+
+	id, err = IdentityFromClaims(oidc, "abc123", map[string][]string{"email": {"jane@example.com"}, "email_verified": {"true"}})
+	require.NoError(t, err)
+	assert.Equal(t, "jane@example.com", id.Login, "a verified email is the fallback username")
+
+	_, err = IdentityFromClaims(oidc, "abc123", map[string][]string{"email": {"jane@example.com"}})
+	assert.ErrorIs(t, err, ErrSSONoLogin, "an unverified email is never a username")
+	id, err = IdentityFromClaims(oidc, "abc123", map[string][]string{"preferred_username": {"jdoe"},
+		"email": {"root@localhost"}, "email_verified": {"false"}})
+	require.NoError(t, err)
+	assert.Empty(t, id.Email, "an unverified email is not kept")
+
+	_, err = IdentityFromClaims(oidc, "abc123", map[string][]string{})
+	assert.ErrorIs(t, err, ErrSSONoLogin, "the opaque subject is never a login")
+	_, err = IdentityFromClaims(oidc, " ", map[string][]string{"preferred_username": {"jdoe"}})
+	assert.ErrorIs(t, err, ErrSSONoSubject)
+
+	saml := &models.SSOProvider{Protocol: models.SSOProtocolSAML}
+	id, err = IdentityFromClaims(saml, "jane@example.com", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "jane@example.com", id.Login)
+	assert.Equal(t, "jane@example.com", id.Email)
+
+	saml.Config.UsernameClaim = "sAMAccountName"
+	id, err = IdentityFromClaims(saml, "jane@example.com", map[string][]string{"sAMAccountName": {"jdoe"}})
+	require.NoError(t, err)
+	assert.Equal(t, "jdoe", id.Login)
+}
+
+func agentGroups(t *testing.T, db *sql.DB, userID int) []string {
+	t.Helper()
+	rows, err := db.Query(`SELECT g.name || ':' || gu.permission_key FROM group_user gu JOIN groups g ON g.id = gu.group_id
+		WHERE gu.user_id = ? ORDER BY g.name`, userID)
+	require.NoError(t, err)
+	defer rows.Close()
+	var out []string
+	for rows.Next() {
+		var s string
+		require.NoError(t, rows.Scan(&s))
+		out = append(out, s)
+	}
+	return out
+}
+
+func TestSSOService_ProvisionAgent(t *testing.T) {
+	svc, db := newSSOTestService(t)
+	ctx := context.Background()
+	p := testOIDCProvider()
+	require.NoError(t, svc.CreateProvider(ctx, p))
+	id := &models.SSOIdentity{Subject: "sub-jane", Login: "jane@example.com", FirstName: "Jane", Groups: []string{"HelpDesk", "helpdesk-admins"}}
+
+	p.JITProvisioning = false
+	_, err := svc.ProvisionAgent(ctx, p, id)
+	assert.ErrorIs(t, err, ErrSSOUnknownAccount)
+
+	p.JITProvisioning = true
+	userID, err := svc.ProvisionAgent(ctx, p, id)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"admin:rw", "support:rw"}, agentGroups(t, db, userID))
+
+	var first, last, pw string
+	require.NoError(t, db.QueryRow("SELECT first_name, last_name, pw FROM users WHERE id = ?", userID).Scan(&first, &last, &pw))
+	assert.Equal(t, "Jane", first)
+	assert.Equal(t, "-", last)
+	assert.NotEmpty(t, pw)
+
+	// Losing an IdP group removes the mapped permission; an admin-granted
+	// group outside the mappings stays.
+	_, err = db.Exec(`INSERT INTO group_user (user_id, group_id, permission_key, create_time, create_by, change_time, change_by)
+		VALUES (?, 1, 'rw', CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`, userID)
+	require.NoError(t, err)
+	id.Groups = []string{"helpdesk"}
+	again, err := svc.ProvisionAgent(ctx, p, id)
+	require.NoError(t, err)
+	assert.Equal(t, userID, again)
+	assert.Equal(t, []string{"support:rw", "users:rw"}, agentGroups(t, db, userID))
+
+	// The link follows the subject, not the username claim.
+	renamed := &models.SSOIdentity{Subject: "sub-jane", Login: "jane.doe"}
+	again, err = svc.ProvisionAgent(ctx, p, renamed)
+	require.NoError(t, err)
+	assert.Equal(t, userID, again)
+
+	_, err = db.Exec(`UPDATE users SET valid_id = 2 WHERE id = ?`, userID)
+	require.NoError(t, err)
+	_, err = svc.ProvisionAgent(ctx, p, id)
+	assert.ErrorIs(t, err, ErrSSOAccountDisabled)
+}
+
+func TestSSOService_ExistingAccountsNeedALink(t *testing.T) {
+	svc, _ := newSSOTestService(t)
+	ctx := context.Background()
+	p := testOIDCProvider()
+	require.NoError(t, svc.CreateProvider(ctx, p))
+
+	// An IdP user who sets their username (or verified email) to an
+	// existing login does not get that account.
+	attacker := &models.SSOIdentity{Subject: "sub-mallory", Login: "root@localhost"}
+	_, err := svc.ProvisionAgent(ctx, p, attacker)
+	assert.ErrorIs(t, err, ErrSSOAccountNotLinked)
+
+	_, err = svc.LinkAccount(ctx, p.ID, "sub-root", "nobody", 1)
+	assert.ErrorIs(t, err, ErrSSOAccountNotFound)
+	link, err := svc.LinkAccount(ctx, p.ID, "sub-root", "root@localhost", 1)
+	require.NoError(t, err)
+	assert.Equal(t, 1, link.UserID)
+	_, err = svc.LinkAccount(ctx, p.ID, "sub-root", "disabled@example.com", 1)
+	assert.ErrorIs(t, err, ErrSSOSubjectLinked)
+
+	userID, err := svc.ProvisionAgent(ctx, p, &models.SSOIdentity{Subject: "sub-root", Login: "admin"})
+	require.NoError(t, err)
+	assert.Equal(t, 1, userID, "the linked subject signs in whatever its username")
+	_, err = svc.ProvisionAgent(ctx, p, attacker)
+	assert.ErrorIs(t, err, ErrSSOAccountNotLinked)
+
+	links, err := svc.ListLinks(ctx, p.ID)
+	require.NoError(t, err)
+	require.Len(t, links, 1)
+	assert.Equal(t, "root@localhost", links[0].Login)
+
+	require.NoError(t, svc.UnlinkAccount(ctx, p.ID, "sub-root"))
+	assert.ErrorIs(t, svc.UnlinkAccount(ctx, p.ID, "sub-root"), ErrSSOLinkNotFound)
+	_, err = svc.ProvisionAgent(ctx, p, &models.SSOIdentity{Subject: "sub-root", Login: "root@localhost"})
+	assert.ErrorIs(t, err, ErrSSOAccountNotLinked)
+}
+
+func TestSSOService_ProvisionCustomer(t *testing.T) {
+	svc, db := newSSOTestService(t)
+	ctx := context.Background()
+	saml := &models.SSOProvider{
+		Name: "Partners", Protocol: models.SSOProtocolSAML, Frontend: models.SSOFrontendCustomer, JITProvisioning: true, CreateBy: 1,
+		Config:        models.SSOProviderConfig{IdPSSOURL: "https://idp.example.com/sso", IdPEntityID: "idp", IdPCertificate: testSSOCertificate(t)},
+		GroupMappings: []models.SSOGroupMapping{{Value: "partners", GroupID: 20, Permission: "ro"}},
+	}
+	require.NoError(t, svc.CreateProvider(ctx, saml))
+	p, err := svc.GetProvider(ctx, saml.ID)
+	require.NoError(t, err)
+
+	login, err := svc.ProvisionCustomer(ctx, p, &models.SSOIdentity{
+		Subject: "bob@acme.example", Login: "bob@acme.example", Email: "bob@acme.example", FirstName: "Bob", LastName: "Buyer", Groups: []string{"partners"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "bob@acme.example", login)
+
+	var customerID string
+	require.NoError(t, db.QueryRow("SELECT customer_id FROM customer_user WHERE login = ?", login).Scan(&customerID))
+	assert.Equal(t, "acme.example", customerID)
+
+	var perm string
+	var value int
+	require.NoError(t, db.QueryRow("SELECT permission_key, permission_value FROM group_customer_user WHERE user_id = ? AND group_id = 20", login).Scan(&perm, &value))
+	assert.Equal(t, "ro", perm)
+	assert.Equal(t, 1, value)
+
+	p.Config.DefaultCustomerID = "PARTNERS"
+	_, err = svc.ProvisionCustomer(ctx, p, &models.SSOIdentity{Subject: "carol", Login: "carol"})
+	require.NoError(t, err)
+	require.NoError(t, db.QueryRow("SELECT customer_id FROM customer_user WHERE login = 'carol'").Scan(&customerID))
+	assert.Equal(t, "PARTNERS", customerID)
+
+	_, err = svc.ProvisionCustomer(ctx, p, &models.SSOIdentity{Subject: "someone-else", Login: "carol"})
+	assert.ErrorIs(t, err, ErrSSOAccountNotLinked)
+}
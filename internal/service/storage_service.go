@@ -2,8 +2,10 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"mime/multipart"
 	"os"
 	"path/filepath"
@@ -245,6 +247,33 @@ func (s *LocalStorageService) GetMetadata(ctx context.Context, path string) (*Fi
 	return metadata, nil
 }
 
+// Usage returns the size of the file at path, or the total size of the
+// files under it when it is a directory. A missing path uses no space.
+func (s *LocalStorageService) Usage(ctx context.Context, path string) (int64, error) {
+	fullPath := filepath.Join(s.basePath, sanitizePath(path))
+	var total int64
+	err := filepath.WalkDir(fullPath, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			total += info.Size()
+		}
+		return nil
+	})
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to measure storage usage: %w", err)
+	}
+	return total, nil
+}
+
 // Helper functions
 
 // sanitizePath cleans a file path to prevent directory traversal attacks.
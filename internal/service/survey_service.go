@@ -0,0 +1,528 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goatkit/goatflow/internal/config"
+	"github.com/goatkit/goatflow/internal/mailqueue"
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/repository"
+)
+
+// Errors returned by SurveyService.
+var (
+	ErrSurveyNotFound     = errors.New("survey not found")
+	ErrSurveyNameRequired = errors.New("survey name is required")
+	ErrSurveyNameExists   = errors.New("a survey with this name already exists")
+	ErrSurveyQuestions    = errors.New("invalid survey questions")
+	ErrSurveyEmail        = errors.New("survey email body must contain the {{link}} placeholder")
+	ErrSurveyInUse        = errors.New("survey was already sent; disable it instead of deleting it")
+	ErrSurveyLinkInvalid  = errors.New("survey link is invalid")
+	ErrSurveyLinkExpired  = errors.New("survey link has expired")
+	ErrSurveyAnswered     = repository.ErrSurveyAnswered
+	ErrSurveyAnswer       = errors.New("invalid survey answer")
+)
+
+const (
+	// surveyDefaultLinkValidDays is how long links stay valid when a survey
+	// sets nothing.
+	surveyDefaultLinkValidDays = 14
+	// surveyMaxQuestions caps the questions of one survey.
+	surveyMaxQuestions = 20
+	// surveyMaxTextAnswer caps the length of free text answers.
+	surveyMaxTextAnswer = 4000
+
+	// SurveyDefaultSubject and SurveyDefaultBody are used for surveys that
+	// set no email of their own.
+	SurveyDefaultSubject = "[Ticket#{{ticket_number}}] How did we do?"
+	SurveyDefaultBody    = "Your request \"{{title}}\" has been closed.\n\n" +
+		"Please take a minute to tell us how satisfied you are with our support:\n\n{{link}}\n\nThank you!\n"
+)
+
+// surveyQuestionID is the form of question IDs, which are also form field
+// names on the survey page.
+var surveyQuestionID = regexp.MustCompile(`^[a-z0-9_-]{1,50}$`)
+
+// surveyMailQueue queues survey emails, normally a
+// *mailqueue.MailQueueRepository.
+type surveyMailQueue interface {
+	Insert(ctx context.Context, item *mailqueue.MailQueueItem) error
+}
+
+// SurveyService manages satisfaction surveys, sends them for closed tickets
+// and records the customers' answers.
+type SurveyService struct {
+	repo    *repository.SurveyRepository
+	mail    surveyMailQueue
+	from    string
+	baseURL string
+	now     func() time.Time
+}
+
+// NewSurveyService creates a survey service. Surveys are queued by email
+// from email.from, with links to app.base_url.
+func NewSurveyService(db *sql.DB) *SurveyService {
+	s := &SurveyService{
+		repo: repository.NewSurveyRepository(db),
+		mail: mailqueue.NewMailQueueRepository(db),
+		now:  time.Now,
+	}
+	if cfg := config.Get(); cfg != nil {
+		s.from = cfg.Email.From
+		s.baseURL = cfg.App.BaseURL
+	}
+	return s
+}
+
+// List returns all surveys, including disabled ones.
+func (s *SurveyService) List(ctx context.Context) ([]*models.Survey, error) {
+	return s.repo.List(ctx, false)
+}
+
+// Get returns a survey.
+func (s *SurveyService) Get(ctx context.Context, id int) (*models.Survey, error) {
+	survey, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if survey == nil {
+		return nil, ErrSurveyNotFound
+	}
+	return survey, nil
+}
+
+// Create validates and stores a new survey.
+func (s *SurveyService) Create(ctx context.Context, survey *models.Survey, userID int) error {
+	if err := s.validate(ctx, survey); err != nil {
+		return err
+	}
+	now := s.now()
+	survey.CreateTime, survey.CreateBy = now, userID
+	survey.ChangeTime, survey.ChangeBy = now, userID
+	return s.repo.Create(ctx, survey)
+}
+
+// Update validates and stores changes to a survey. Requests already sent
+// keep their links; answers are validated against the current questions.
+func (s *SurveyService) Update(ctx context.Context, survey *models.Survey, userID int) error {
+	existing, err := s.Get(ctx, survey.ID)
+	if err != nil {
+		return err
+	}
+	if err := s.validate(ctx, survey); err != nil {
+		return err
+	}
+	survey.CreateTime, survey.CreateBy = existing.CreateTime, existing.CreateBy
+	survey.ChangeTime, survey.ChangeBy = s.now(), userID
+	if err := s.repo.Update(ctx, survey); err == sql.ErrNoRows {
+		return ErrSurveyNotFound
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+// Delete deletes a survey that was never sent. Surveys with responses can
+// only be disabled, so their results stay available.
+func (s *SurveyService) Delete(ctx context.Context, id int) error {
+	if _, err := s.Get(ctx, id); err != nil {
+		return err
+	}
+	sent, err := s.repo.HasRequests(ctx, id)
+	if err != nil {
+		return err
+	}
+	if sent {
+		return ErrSurveyInUse
+	}
+	deleted, err := s.repo.Delete(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrSurveyNotFound
+	}
+	return nil
+}
+
+// validate checks a survey and fills in defaults.
+func (s *SurveyService) validate(ctx context.Context, survey *models.Survey) error {
+	survey.Name = strings.TrimSpace(survey.Name)
+	if survey.Name == "" {
+		return ErrSurveyNameRequired
+	}
+	if err := ValidateSurveyQuestions(survey.Questions); err != nil {
+		return err
+	}
+	if survey.SendDelayMinutes < 0 {
+		survey.SendDelayMinutes = 0
+	}
+	if survey.LinkValidDays <= 0 {
+		survey.LinkValidDays = surveyDefaultLinkValidDays
+	}
+	if strings.TrimSpace(survey.EmailSubject) == "" {
+		survey.EmailSubject = SurveyDefaultSubject
+	}
+	if strings.TrimSpace(survey.EmailBody) == "" {
+		survey.EmailBody = SurveyDefaultBody
+	}
+	if !strings.Contains(survey.EmailBody, "{{link}}") {
+		return ErrSurveyEmail
+	}
+	if survey.ValidID == 0 {
+		survey.ValidID = 1
+	}
+	exists, err := s.repo.NameExists(ctx, survey.Name, survey.ID)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return ErrSurveyNameExists
+	}
+	return nil
+}
+
+// ValidateSurveyQuestions checks that questions have unique IDs usable as
+// form field names, a known type and a label, and that choice questions
+// have options.
+func ValidateSurveyQuestions(questions []models.SurveyQuestion) error {
+	if len(questions) == 0 || len(questions) > surveyMaxQuestions {
+		return fmt.Errorf("%w: a survey needs 1 to %d questions", ErrSurveyQuestions, surveyMaxQuestions)
+	}
+	seen := make(map[string]bool, len(questions))
+	for i := range questions {
+		q := &questions[i]
+		q.Label = strings.TrimSpace(q.Label)
+		switch {
+		case !surveyQuestionID.MatchString(q.ID):
+			return fmt.Errorf("%w: question id %q must be 1-50 lowercase letters, digits, - or _", ErrSurveyQuestions, q.ID)
+		case seen[q.ID]:
+			return fmt.Errorf("%w: duplicate question id %q", ErrSurveyQuestions, q.ID)
+		case !q.Type.IsValid():
+			return fmt.Errorf("%w: question %q has unknown type %q", ErrSurveyQuestions, q.ID, q.Type)
+		case q.Label == "":
+			return fmt.Errorf("%w: question %q needs a label", ErrSurveyQuestions, q.ID)
+		case q.Type == models.SurveyQuestionChoice && len(q.Options) < 2:
+			return fmt.Errorf("%w: choice question %q needs at least two options", ErrSurveyQuestions, q.ID)
+		}
+		if q.Type != models.SurveyQuestionChoice {
+			q.Options = nil
+		}
+		seen[q.ID] = true
+	}
+	return nil
+}
+
+// Dispatch sends the valid surveys for tickets that have been closed for
+// their send delay, at most limit in one run, and returns how many were
+// sent. Each ticket is surveyed once, by the first matching survey in name
+// order; tickets closed before a survey was created are not surveyed by it.
+func (s *SurveyService) Dispatch(ctx context.Context, limit int) (int, error) {
+	surveys, err := s.repo.List(ctx, true)
+	if err != nil {
+		return 0, err
+	}
+	now := s.now()
+	sent := 0
+	for _, survey := range surveys {
+		if sent >= limit {
+			break
+		}
+		closedBefore := now.Add(-time.Duration(survey.SendDelayMinutes) * time.Minute)
+		candidates, err := s.repo.Candidates(ctx, survey.QueueIDs, survey.CreateTime, closedBefore, limit-sent)
+		if err != nil {
+			return sent, err
+		}
+		for _, c := range candidates {
+			if err := s.send(ctx, survey, c, now); err != nil {
+				log.Printf("survey: sending survey %d for ticket %s failed: %v", survey.ID, c.TicketNumber, err)
+				continue
+			}
+			sent++
+		}
+	}
+	return sent, nil
+}
+
+// send records a survey request for the ticket and queues the email with
+// its link. The token itself is only part of the link. When the email
+// cannot be queued the request is removed again, so the ticket is retried
+// on the next run.
+func (s *SurveyService) send(ctx context.Context, survey *models.Survey, c models.SurveyCandidate, now time.Time) error {
+	token, hash, err := newSurveyToken()
+	if err != nil {
+		return err
+	}
+	req := &models.SurveyRequest{
+		SurveyID:  survey.ID,
+		TicketID:  c.TicketID,
+		SentAt:    now,
+		ExpiresAt: now.AddDate(0, 0, survey.LinkValidDays),
+	}
+	if err := s.repo.CreateRequest(ctx, req, hash); err != nil {
+		return err
+	}
+
+	replacer := strings.NewReplacer(
+		"{{link}}", strings.TrimRight(s.baseURL, "/")+"/customer/survey/"+token,
+		"{{ticket_number}}", c.TicketNumber,
+		"{{title}}", c.Title,
+	)
+	sender := s.from
+	err = s.mail.Insert(ctx, &mailqueue.MailQueueItem{
+		Sender:     &sender,
+		Recipient:  c.Email,
+		RawMessage: mailqueue.BuildEmailMessage(s.from, c.Email, replacer.Replace(survey.EmailSubject), replacer.Replace(survey.EmailBody)),
+		CreateTime: now,
+	})
+	if err != nil {
+		if delErr := s.repo.DeleteRequest(ctx, req.ID); delErr != nil {
+			log.Printf("survey: removing unsent request %d failed: %v", req.ID, delErr)
+		}
+		return err
+	}
+	return nil
+}
+
+// newSurveyToken returns a random link token and the hash stored for it.
+func newSurveyToken() (string, string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(buf)
+	return token, surveyTokenHash(token), nil
+}
+
+func surveyTokenHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Open returns the survey and request of a link token. It fails when the
+// link is unknown, expired or was answered already.
+func (s *SurveyService) Open(ctx context.Context, token string) (*models.Survey, *models.SurveyRequest, error) {
+	if token == "" {
+		return nil, nil, ErrSurveyLinkInvalid
+	}
+	req, err := s.repo.RequestByTokenHash(ctx, surveyTokenHash(token))
+	if err != nil {
+		return nil, nil, err
+	}
+	if req == nil {
+		return nil, nil, ErrSurveyLinkInvalid
+	}
+	if req.AnsweredAt != nil {
+		return nil, req, ErrSurveyAnswered
+	}
+	if !s.now().Before(req.ExpiresAt) {
+		return nil, req, ErrSurveyLinkExpired
+	}
+	survey, err := s.repo.Get(ctx, req.SurveyID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if survey == nil {
+		return nil, nil, ErrSurveyLinkInvalid
+	}
+	return survey, req, nil
+}
+
+// Submit records the answers given through a survey link, keyed by
+// question ID.
+func (s *SurveyService) Submit(ctx context.Context, token string, values map[string]string) error {
+	survey, req, err := s.Open(ctx, token)
+	if err != nil {
+		return err
+	}
+	answers, err := ParseSurveyAnswers(survey, values)
+	if err != nil {
+		return err
+	}
+	return s.repo.SaveAnswers(ctx, req.ID, answers, s.now())
+}
+
+// ParseSurveyAnswers validates form values against a survey's questions.
+// Unanswered optional questions are left out; at least one question must
+// be answered.
+func ParseSurveyAnswers(survey *models.Survey, values map[string]string) ([]models.SurveyAnswer, error) {
+	answers := make([]models.SurveyAnswer, 0, len(survey.Questions))
+	for _, q := range survey.Questions {
+		v := strings.TrimSpace(values[q.ID])
+		if v == "" {
+			if q.Required {
+				return nil, fmt.Errorf("%w: %q is required", ErrSurveyAnswer, q.Label)
+			}
+			continue
+		}
+		a := models.SurveyAnswer{QuestionID: q.ID, QuestionType: q.Type}
+		switch q.Type {
+		case models.SurveyQuestionRating, models.SurveyQuestionNPS:
+			low, high := q.Type.Scale()
+			n, err := strconv.Atoi(v)
+			if err != nil || n < low || n > high {
+				return nil, fmt.Errorf("%w: %q must be between %d and %d", ErrSurveyAnswer, q.Label, low, high)
+			}
+			a.Rating = &n
+		case models.SurveyQuestionChoice:
+			valid := false
+			for _, option := range q.Options {
+				if option == v {
+					valid = true
+					break
+				}
+			}
+			if !valid {
+				return nil, fmt.Errorf("%w: %q is not an option of %q", ErrSurveyAnswer, v, q.Label)
+			}
+			a.Answer = v
+		default:
+			if len(v) > surveyMaxTextAnswer {
+				v = v[:surveyMaxTextAnswer]
+			}
+			a.Answer = v
+		}
+		answers = append(answers, a)
+	}
+	if len(answers) == 0 {
+		return nil, fmt.Errorf("%w: please answer at least one question", ErrSurveyAnswer)
+	}
+	return answers, nil
+}
+
+// ListResponses returns the answered requests of a survey, most recently
+// answered first.
+func (s *SurveyService) ListResponses(ctx context.Context, surveyID, limit, offset int) ([]*models.SurveyRequest, error) {
+	if _, err := s.Get(ctx, surveyID); err != nil {
+		return nil, err
+	}
+	return s.repo.ListResponses(ctx, surveyID, limit, offset)
+}
+
+// Stats aggregates the surveys sent in [from, to): of one survey with
+// per-question results, or of all surveys when surveyID is 0.
+func (s *SurveyService) Stats(ctx context.Context, surveyID int, from, to time.Time) (*models.SurveyStats, error) {
+	var survey *models.Survey
+	if surveyID > 0 {
+		var err error
+		if survey, err = s.Get(ctx, surveyID); err != nil {
+			return nil, err
+		}
+	}
+	sent, answered, err := s.repo.CountRequests(ctx, surveyID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	answers, err := s.repo.Answers(ctx, surveyID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	stats := AggregateSurvey(survey, sent, answered, answers)
+	stats.SurveyID, stats.From, stats.To = surveyID, from, to
+	return &stats, nil
+}
+
+// AggregateSurvey computes survey results from request counts and answers.
+// Per-question results are only computed when survey is given.
+func AggregateSurvey(survey *models.Survey, sent, answered int, answers []models.SurveyAnswer) models.SurveyStats {
+	stats := models.SurveyStats{Sent: sent, Answered: answered}
+	if sent > 0 {
+		stats.ResponseRate = round1(float64(answered) / float64(sent) * 100)
+	}
+	stats.AverageRating = averageRating(answers, func(a models.SurveyAnswer) bool {
+		return a.QuestionType == models.SurveyQuestionRating
+	})
+	stats.NPS = netPromoterScore(answers, func(a models.SurveyAnswer) bool {
+		return a.QuestionType == models.SurveyQuestionNPS
+	})
+	if survey == nil {
+		return stats
+	}
+
+	stats.Questions = make([]models.SurveyQuestionStats, 0, len(survey.Questions))
+	for _, q := range survey.Questions {
+		match := func(a models.SurveyAnswer) bool { return a.QuestionID == q.ID && a.QuestionType == q.Type }
+		qs := models.SurveyQuestionStats{QuestionID: q.ID, Label: q.Label, Type: q.Type}
+		if q.Type != models.SurveyQuestionText {
+			qs.Distribution = make(map[string]int)
+		}
+		for _, a := range answers {
+			if !match(a) {
+				continue
+			}
+			qs.Responses++
+			switch {
+			case a.Rating != nil:
+				qs.Distribution[strconv.Itoa(*a.Rating)]++
+			case q.Type == models.SurveyQuestionChoice:
+				qs.Distribution[a.Answer]++
+			}
+		}
+		switch q.Type {
+		case models.SurveyQuestionRating:
+			qs.Average = averageRating(answers, match)
+		case models.SurveyQuestionNPS:
+			qs.Average = averageRating(answers, match)
+			qs.NPS = netPromoterScore(answers, match)
+		}
+		stats.Questions = append(stats.Questions, qs)
+	}
+	return stats
+}
+
+// averageRating returns the mean rating of the matching answers, or nil if
+// there are none.
+func averageRating(answers []models.SurveyAnswer, match func(models.SurveyAnswer) bool) *float64 {
+	sum, n := 0, 0
+	for _, a := range answers {
+		if a.Rating != nil && match(a) {
+			sum += *a.Rating
+			n++
+		}
+	}
+	if n == 0 {
+		return nil
+	}
+	avg := math.Round(float64(sum)/float64(n)*100) / 100
+	return &avg
+}
+
+// netPromoterScore returns the percentage of promoters (9-10) minus that
+// of detractors (0-6) among the matching answers, or nil if there are none.
+func netPromoterScore(answers []models.SurveyAnswer, match func(models.SurveyAnswer) bool) *float64 {
+	promoters, detractors, n := 0, 0, 0
+	for _, a := range answers {
+		if a.Rating == nil || !match(a) {
+			continue
+		}
+		n++
+		switch {
+		case *a.Rating >= 9:
+			promoters++
+		case *a.Rating <= 6:
+			detractors++
+		}
+	}
+	if n == 0 {
+		return nil
+	}
+	nps := round1(float64(promoters-detractors) / float64(n) * 100)
+	return &nps
+}
+
+func round1(v float64) float64 {
+	return math.Round(v*10) / 10
+}
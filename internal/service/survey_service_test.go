@@ -0,0 +1,187 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/mailqueue"
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/testutil"
+)
+
+// stubMailQueue records queued emails and fails with err when it is set.
+type stubMailQueue struct {
+	items []*mailqueue.MailQueueItem
+	err   error
+}
+
+func (q *stubMailQueue) Insert(_ context.Context, item *mailqueue.MailQueueItem) error {
+	if q.err != nil {
+		return q.err
+	}
+	q.items = append(q.items, item)
+	return nil
+}
+
+func surveyTestQuestions() []models.SurveyQuestion {
+	return []models.SurveyQuestion{
+		{ID: "overall", Type: models.SurveyQuestionRating, Label: "How satisfied are you?", Required: true},
+		{ID: "recommend", Type: models.SurveyQuestionNPS, Label: "Would you recommend us?"},
+		{ID: "channel", Type: models.SurveyQuestionChoice, Label: "How did you contact us?", Options: []string{"Email", "Phone"}},
+		{ID: "comment", Type: models.SurveyQuestionText, Label: "Anything else?"},
+	}
+}
+
+func TestValidateSurveyQuestions(t *testing.T) {
+	require.NoError(t, ValidateSurveyQuestions(surveyTestQuestions()))
+
+	for name, questions := range map[string][]models.SurveyQuestion{
+		"none":            nil,
+		"bad id":          {{ID: "Overall rating", Type: models.SurveyQuestionRating, Label: "x"}},
+		"duplicate id":    {{ID: "a", Type: models.SurveyQuestionText, Label: "x"}, {ID: "a", Type: models.SurveyQuestionText, Label: "y"}},
+		"unknown type":    {{ID: "a", Type: "stars", Label: "x"}},
+		"no label":        {{ID: "a", Type: models.SurveyQuestionText, Label: " "}},
+		"choice w/o opts": {{ID: "a", Type: models.SurveyQuestionChoice, Label: "x", Options: []string{"Only"}}},
+	} {
+		assert.ErrorIs(t, ValidateSurveyQuestions(questions), ErrSurveyQuestions, name)
+	}
+}
+
+func TestParseSurveyAnswers(t *testing.T) {
+	survey := &models.Survey{Questions: surveyTestQuestions()}
+
+	answers, err := ParseSurveyAnswers(survey, map[string]string{"overall": "5", "channel": "Phone", "comment": " Great "})
+	require.NoError(t, err)
+	require.Len(t, answers, 3)
+	assert.Equal(t, 5, *answers[0].Rating)
+	assert.Equal(t, "Phone", answers[1].Answer)
+	assert.Equal(t, "Great", answers[2].Answer)
+
+	for name, values := range map[string]map[string]string{
+		"required missing": {"recommend": "9"},
+		"rating too high":  {"overall": "6"},
+		"nps not a number": {"overall": "3", "recommend": "ten"},
+		"unknown option":   {"overall": "3", "channel": "Fax"},
+	} {
+		_, err := ParseSurveyAnswers(survey, values)
+		assert.ErrorIs(t, err, ErrSurveyAnswer, name)
+	}
+
+	_, err = ParseSurveyAnswers(&models.Survey{Questions: surveyTestQuestions()[3:]}, map[string]string{})
+	assert.ErrorIs(t, err, ErrSurveyAnswer, "at least one answer")
+}
+
+func TestAggregateSurvey(t *testing.T) {
+	rating := func(id string, typ models.SurveyQuestionType, n int) models.SurveyAnswer {
+		return models.SurveyAnswer{QuestionID: id, QuestionType: typ, Rating: &n}
+	}
+	answers := []models.SurveyAnswer{
+		rating("overall", models.SurveyQuestionRating, 5),
+		rating("overall", models.SurveyQuestionRating, 4),
+		rating("overall", models.SurveyQuestionRating, 2),
+		rating("recommend", models.SurveyQuestionNPS, 10),
+		rating("recommend", models.SurveyQuestionNPS, 9),
+		rating("recommend", models.SurveyQuestionNPS, 7),
+		rating("recommend", models.SurveyQuestionNPS, 3),
+		{QuestionID: "channel", QuestionType: models.SurveyQuestionChoice, Answer: "Email"},
+		{QuestionID: "comment", QuestionType: models.SurveyQuestionText, Answer: "Thanks"},
+	}
+
+	stats := AggregateSurvey(&models.Survey{Questions: surveyTestQuestions()}, 8, 4, answers)
+	assert.Equal(t, 50.0, stats.ResponseRate)
+	require.NotNil(t, stats.AverageRating)
+	assert.Equal(t, 3.67, *stats.AverageRating)
+	require.NotNil(t, stats.NPS)
+	assert.Equal(t, 25.0, *stats.NPS)
+
+	require.Len(t, stats.Questions, 4)
+	assert.Equal(t, 3, stats.Questions[0].Responses)
+	assert.Equal(t, map[string]int{"5": 1, "4": 1, "2": 1}, stats.Questions[0].Distribution)
+	assert.Equal(t, 7.25, *stats.Questions[1].Average)
+	assert.Equal(t, map[string]int{"Email": 1}, stats.Questions[2].Distribution)
+	assert.Equal(t, 1, stats.Questions[3].Responses)
+	assert.Nil(t, stats.Questions[3].Distribution)
+
+	overall := AggregateSurvey(nil, 0, 0, nil)
+	assert.Zero(t, overall.ResponseRate)
+	assert.Nil(t, overall.AverageRating)
+	assert.Nil(t, overall.Questions)
+}
+
+func TestSurveyService_DispatchAndSubmit(t *testing.T) {
+	db := testutil.UseMigratedDB(t)
+	_, err := db.Exec(`INSERT INTO users (id, login, pw, first_name, last_name, valid_id, create_time, create_by, change_time, change_by)
+		VALUES (1, 'root@localhost', 'x', 'Admin', 'OTRS', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`)
+	require.NoError(t, err)
+
+	now := time.Now().UTC().Truncate(time.Second)
+	mail := &stubMailQueue{err: errors.New("mail queue down")}
+	svc := NewSurveyService(db)
+	svc.mail = mail
+	svc.from, svc.baseURL = "support@example.com", "https://help.example.com/"
+	svc.now = func() time.Time { return now.Add(-24 * time.Hour) }
+	ctx := context.Background()
+
+	survey := &models.Survey{Name: "After close", Questions: surveyTestQuestions(), SendDelayMinutes: 30}
+	require.NoError(t, svc.Create(ctx, survey, 1))
+	assert.Equal(t, SurveyDefaultSubject, survey.EmailSubject)
+	assert.Equal(t, 14, survey.LinkValidDays)
+	assert.ErrorIs(t, svc.Create(ctx, &models.Survey{Name: "After close", Questions: surveyTestQuestions()}, 1), ErrSurveyNameExists)
+
+	closed := now.Add(-time.Hour)
+	_, err = db.Exec(`INSERT INTO ticket (id, tn, title, queue_id, ticket_lock_id, user_id, responsible_user_id,
+		ticket_priority_id, ticket_state_id, customer_user_id, timeout, until_time, escalation_time, escalation_update_time,
+		escalation_response_time, escalation_solution_time, create_time, create_by, change_time, change_by)
+		VALUES (1, '2025010100001', 'Printer', 1, 1, 1, 1, 3, 4, 'ann@example.com', 0, 0, 0, 0, 0, 0, ?, 1, ?, 1)`,
+		closed, closed)
+	require.NoError(t, err)
+
+	svc.now = func() time.Time { return now }
+	sent, err := svc.Dispatch(ctx, 10)
+	require.NoError(t, err)
+	assert.Zero(t, sent, "unqueued surveys are retried")
+	mail.err = nil
+	sent, err = svc.Dispatch(ctx, 10)
+	require.NoError(t, err)
+	assert.Equal(t, 1, sent)
+	sent, err = svc.Dispatch(ctx, 10)
+	require.NoError(t, err)
+	assert.Zero(t, sent, "tickets are surveyed once")
+	assert.ErrorIs(t, svc.Delete(ctx, survey.ID), ErrSurveyInUse)
+
+	require.Len(t, mail.items, 1)
+	assert.Equal(t, "ann@example.com", mail.items[0].Recipient)
+	raw := string(mail.items[0].RawMessage)
+	assert.Contains(t, raw, "[Ticket#2025010100001] How did we do?")
+	m := regexp.MustCompile(`https://help\.example\.com/customer/survey/([A-Za-z0-9_-]+)`).FindStringSubmatch(raw)
+	require.Len(t, m, 2)
+	token := m[1]
+
+	_, _, err = svc.Open(ctx, "not-a-token")
+	assert.ErrorIs(t, err, ErrSurveyLinkInvalid)
+	opened, req, err := svc.Open(ctx, token)
+	require.NoError(t, err)
+	assert.Equal(t, survey.ID, opened.ID)
+	assert.Equal(t, int64(1), req.TicketID)
+
+	assert.ErrorIs(t, svc.Submit(ctx, token, map[string]string{"comment": "No rating"}), ErrSurveyAnswer)
+	require.NoError(t, svc.Submit(ctx, token, map[string]string{"overall": "4", "recommend": "9"}))
+	assert.ErrorIs(t, svc.Submit(ctx, token, map[string]string{"overall": "1"}), ErrSurveyAnswered)
+
+	stats, err := svc.Stats(ctx, survey.ID, now.Add(-time.Hour), now.Add(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, 1, stats.Sent)
+	assert.Equal(t, 1, stats.Answered)
+	assert.Equal(t, 4.0, *stats.AverageRating)
+	assert.Equal(t, 100.0, *stats.NPS)
+
+	svc.now = func() time.Time { return now.AddDate(0, 0, 15) }
+	_, _, err = svc.Open(ctx, token)
+	assert.ErrorIs(t, err, ErrSurveyAnswered)
+}
@@ -0,0 +1,664 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/repository"
+	"github.com/goatkit/goatflow/internal/sysconfig"
+)
+
+// Errors returned by SysconfigService. Validation failures are returned as
+// *SysconfigValidationError, which wraps ErrSysconfigInvalid.
+var (
+	ErrSysconfigNotFound           = errors.New("setting not found")
+	ErrSysconfigInvalid            = errors.New("invalid settings")
+	ErrSysconfigDeploymentNotFound = errors.New("deployment not found")
+	ErrSysconfigDeploymentFormat   = errors.New("deployment was not made by GoatFlow and cannot be restored")
+)
+
+// SysconfigValidationError lists the rejected settings of an update, with
+// one message per setting name.
+type SysconfigValidationError struct {
+	Errors map[string]string
+}
+
+func (e *SysconfigValidationError) Error() string {
+	names := make([]string, 0, len(e.Errors))
+	for name := range e.Errors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = name + " " + e.Errors[name]
+	}
+	return fmt.Sprintf("%v: %s", ErrSysconfigInvalid, strings.Join(parts, "; "))
+}
+
+func (e *SysconfigValidationError) Unwrap() error { return ErrSysconfigInvalid }
+
+// SysconfigFilter narrows SysconfigService.List. Navigation matches the
+// group and its sub-groups; Search matches name and description.
+type SysconfigFilter struct {
+	Navigation   string
+	Search       string
+	ModifiedOnly bool
+}
+
+// sysconfigSecretMask replaces password values in diffs and deployments.
+const sysconfigSecretMask = "********"
+
+// sysconfigSnapshotVersion identifies the deployment snapshot format, so
+// snapshots written by OTRS are not mistaken for ours.
+const sysconfigSnapshotVersion = 1
+
+type sysconfigSnapshot struct {
+	Version  int               `json:"version"`
+	Settings map[string]string `json:"settings"`
+}
+
+// restorable returns the recorded values of the settings a restore may
+// change: those that are visible, writable and part of the snapshot.
+func (snap sysconfigSnapshot) restorable(entries []sysconfigEntry) map[string]string {
+	values := map[string]string{}
+	for _, e := range entries {
+		v, ok := snap.Settings[e.rec.Name]
+		if ok && !e.rec.Invisible && !e.rec.ReadOnly {
+			values[e.rec.Name] = v
+		}
+	}
+	return values
+}
+
+// SysconfigService reads, validates and deploys system configuration
+// settings. Defaults live in sysconfig_default and global overrides in
+// sysconfig_modified; a deployment is a versioned snapshot of the effective
+// values that can be restored later.
+type SysconfigService struct {
+	repo *repository.SysconfigRepository
+}
+
+// NewSysconfigService creates a sysconfig service.
+func NewSysconfigService(db *sql.DB) *SysconfigService {
+	return &SysconfigService{repo: repository.NewSysconfigRepository(db)}
+}
+
+// sysconfigEntry is a setting with its parsed schema.
+type sysconfigEntry struct {
+	rec    *repository.SysconfigRecord
+	schema sysconfig.Schema
+}
+
+func (e sysconfigEntry) value() string {
+	if e.rec.Modified != nil {
+		return *e.rec.Modified
+	}
+	return e.rec.Default
+}
+
+func (e sysconfigEntry) secret() bool { return e.schema.Type == sysconfig.TypePassword }
+
+func (s *SysconfigService) entries(ctx context.Context) ([]sysconfigEntry, error) {
+	records, err := s.repo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]sysconfigEntry, 0, len(records))
+	for _, rec := range records {
+		// A broken schema degrades to a plain string setting.
+		schema, _ := sysconfig.ParseSchema(rec.Schema)
+		entries = append(entries, sysconfigEntry{rec: rec, schema: schema})
+	}
+	return entries, nil
+}
+
+func (e sysconfigEntry) setting() *models.SysconfigSetting {
+	value := e.value()
+	st := &models.SysconfigSetting{
+		ID:          e.rec.DefaultID,
+		Name:        e.rec.Name,
+		Description: e.rec.Description,
+		Navigation:  e.rec.Navigation,
+		Type:        e.schema.Type,
+		Min:         e.schema.Min,
+		Max:         e.schema.Max,
+		Validation:  e.schema.Validation,
+		ReadOnly:    e.rec.ReadOnly,
+		Required:    e.rec.Required,
+		Default:     e.rec.Default,
+		Value:       value,
+		TypedValue:  e.schema.TypedValue(value),
+		Modified:    e.rec.Modified != nil,
+		ChangeBy:    e.rec.ChangeBy,
+	}
+	for _, opt := range e.schema.Options {
+		st.Options = append(st.Options, models.SysconfigOption{Value: opt.Value, Label: opt.Label})
+	}
+	if e.rec.Modified != nil {
+		changed := e.rec.ChangeTime
+		st.ChangeTime = &changed
+	}
+	if e.secret() {
+		st.Secret = true
+		st.Default, st.Value, st.TypedValue = "", "", ""
+	}
+	return st
+}
+
+// List returns the visible settings matching the filter, ordered by
+// navigation and name. Password values are withheld.
+func (s *SysconfigService) List(ctx context.Context, f SysconfigFilter) ([]*models.SysconfigSetting, error) {
+	entries, err := s.entries(ctx)
+	if err != nil {
+		return nil, err
+	}
+	search := strings.ToLower(strings.TrimSpace(f.Search))
+	settings := []*models.SysconfigSetting{}
+	for _, e := range entries {
+		if e.rec.Invisible {
+			continue
+		}
+		if f.Navigation != "" && e.rec.Navigation != f.Navigation &&
+			!strings.HasPrefix(e.rec.Navigation, f.Navigation+"::") {
+			continue
+		}
+		if search != "" && !strings.Contains(strings.ToLower(e.rec.Name), search) &&
+			!strings.Contains(strings.ToLower(e.rec.Description), search) {
+			continue
+		}
+		if f.ModifiedOnly && e.rec.Modified == nil {
+			continue
+		}
+		settings = append(settings, e.setting())
+	}
+	return settings, nil
+}
+
+// Get returns a single setting by name.
+func (s *SysconfigService) Get(ctx context.Context, name string) (*models.SysconfigSetting, error) {
+	entries, err := s.entries(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		if e.rec.Name == name && !e.rec.Invisible {
+			return e.setting(), nil
+		}
+	}
+	return nil, ErrSysconfigNotFound
+}
+
+// Navigations returns the distinct navigation groups of the visible
+// settings, sorted.
+func (s *SysconfigService) Navigations(ctx context.Context) ([]string, error) {
+	entries, err := s.entries(ctx)
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	navs := []string{}
+	for _, e := range entries {
+		if e.rec.Invisible || e.rec.Navigation == "" || seen[e.rec.Navigation] {
+			continue
+		}
+		seen[e.rec.Navigation] = true
+		navs = append(navs, e.rec.Navigation)
+	}
+	sort.Strings(navs)
+	return navs, nil
+}
+
+// Update sets the given settings and resets the named ones to their
+// defaults. Values are normalized and validated against each setting's
+// schema; if any is rejected nothing is saved and the error is a
+// *SysconfigValidationError. A value equal to the default removes the
+// override. It returns the changed settings.
+func (s *SysconfigService) Update(ctx context.Context, values map[string]string, resets []string, userID int) ([]*models.SysconfigSetting, error) {
+	entries, err := s.entries(ctx)
+	if err != nil {
+		return nil, err
+	}
+	changes, err := planSysconfigChanges(entries, values, resets)
+	if err != nil {
+		return nil, err
+	}
+	if len(changes) == 0 {
+		return []*models.SysconfigSetting{}, nil
+	}
+	if err := s.repo.Save(ctx, changes, userID); err != nil {
+		return nil, err
+	}
+	return s.changedSettings(ctx, changes)
+}
+
+// planSysconfigChanges validates values and resets against the settings
+// and returns the changes to store. Unchanged values are dropped.
+func planSysconfigChanges(entries []sysconfigEntry, values map[string]string, resets []string) ([]repository.SysconfigChange, error) {
+	byName := make(map[string]sysconfigEntry, len(entries))
+	for _, e := range entries {
+		if !e.rec.Invisible {
+			byName[e.rec.Name] = e
+		}
+	}
+
+	verr := &SysconfigValidationError{Errors: map[string]string{}}
+	var changes []repository.SysconfigChange
+	lookup := func(name string) (sysconfigEntry, bool) {
+		e, ok := byName[name]
+		switch {
+		case !ok:
+			verr.Errors[name] = "does not exist"
+		case e.rec.ReadOnly:
+			verr.Errors[name] = "is read-only"
+			ok = false
+		}
+		return e, ok
+	}
+
+	for _, name := range resets {
+		if _, both := values[name]; both {
+			verr.Errors[name] = "cannot be set and reset at once"
+			continue
+		}
+		if e, ok := lookup(name); ok && e.rec.Modified != nil {
+			changes = append(changes, repository.SysconfigChange{DefaultID: e.rec.DefaultID, Name: name})
+		}
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if _, reset := verr.Errors[name]; reset {
+			continue
+		}
+		e, ok := lookup(name)
+		if !ok {
+			continue
+		}
+		value := e.schema.Normalize(values[name])
+		if e.rec.Required && strings.TrimSpace(value) == "" {
+			verr.Errors[name] = "is required"
+			continue
+		}
+		if err := e.schema.Validate(value); err != nil {
+			verr.Errors[name] = err.Error()
+			continue
+		}
+		switch {
+		case value == e.value():
+		case value == e.rec.Default:
+			changes = append(changes, repository.SysconfigChange{DefaultID: e.rec.DefaultID, Name: name})
+		default:
+			v := value
+			changes = append(changes, repository.SysconfigChange{DefaultID: e.rec.DefaultID, Name: name, Value: &v})
+		}
+	}
+
+	if len(verr.Errors) > 0 {
+		return nil, verr
+	}
+	return changes, nil
+}
+
+// changedSettings reads back the settings touched by changes.
+func (s *SysconfigService) changedSettings(ctx context.Context, changes []repository.SysconfigChange) ([]*models.SysconfigSetting, error) {
+	changed := make(map[string]bool, len(changes))
+	for _, ch := range changes {
+		changed[ch.Name] = true
+	}
+	entries, err := s.entries(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*models.SysconfigSetting, 0, len(changed))
+	for _, e := range entries {
+		if changed[e.rec.Name] {
+			result = append(result, e.setting())
+		}
+	}
+	return result, nil
+}
+
+// Diff returns the settings that are modified from their default or that
+// changed since the last deployment, which is what the next deployment
+// would record.
+func (s *SysconfigService) Diff(ctx context.Context) ([]models.SysconfigDiffEntry, error) {
+	entries, err := s.entries(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var deployed map[string]string
+	if latest, err := s.repo.ListDeployments(ctx, 1); err != nil {
+		return nil, err
+	} else if len(latest) == 1 {
+		if snap, ok := decodeSysconfigSnapshot(latest[0].Snapshot); ok {
+			deployed = snap.Settings
+		}
+	}
+
+	diff := []models.SysconfigDiffEntry{}
+	for _, e := range entries {
+		if e.rec.Invisible {
+			continue
+		}
+		entry := models.SysconfigDiffEntry{Name: e.rec.Name, Default: e.rec.Default, Value: e.value()}
+		if deployed == nil {
+			entry.Pending = e.rec.Modified != nil
+		} else if v, ok := deployed[e.rec.Name]; ok {
+			entry.Deployed = &v
+			entry.Pending = v != entry.Value
+		} else {
+			entry.Pending = entry.Value != e.rec.Default
+		}
+		if e.rec.Modified == nil && !entry.Pending {
+			continue
+		}
+		if e.secret() {
+			entry.Default, entry.Value = sysconfigSecretMask, sysconfigSecretMask
+			if entry.Deployed != nil {
+				masked := sysconfigSecretMask
+				entry.Deployed = &masked
+			}
+		}
+		diff = append(diff, entry)
+	}
+	return diff, nil
+}
+
+// Deploy records a snapshot of every effective setting value.
+func (s *SysconfigService) Deploy(ctx context.Context, comments string, userID int) (*models.SysconfigDeployment, error) {
+	entries, err := s.entries(ctx)
+	if err != nil {
+		return nil, err
+	}
+	data, err := sysconfigSnapshotData(entries, nil)
+	if err != nil {
+		return nil, err
+	}
+	id, err := s.repo.Deploy(ctx, nil, strings.TrimSpace(comments), data, userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.Deployment(ctx, id)
+}
+
+// sysconfigSnapshotData encodes the effective values the settings will
+// have once changes are applied.
+func sysconfigSnapshotData(entries []sysconfigEntry, changes []repository.SysconfigChange) ([]byte, error) {
+	snap := sysconfigSnapshot{Version: sysconfigSnapshotVersion, Settings: make(map[string]string, len(entries))}
+	for _, e := range entries {
+		snap.Settings[e.rec.Name] = e.value()
+	}
+	for _, ch := range changes {
+		if ch.Value != nil {
+			snap.Settings[ch.Name] = *ch.Value
+			continue
+		}
+		for _, e := range entries {
+			if e.rec.Name == ch.Name {
+				snap.Settings[ch.Name] = e.rec.Default
+			}
+		}
+	}
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return nil, fmt.Errorf("encode sysconfig snapshot: %w", err)
+	}
+	return data, nil
+}
+
+// Deployments returns up to limit deployments, newest first, without their
+// settings.
+func (s *SysconfigService) Deployments(ctx context.Context, limit int) ([]*models.SysconfigDeployment, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	records, err := s.repo.ListDeployments(ctx, limit)
+	if err != nil {
+		return nil, err
+	}
+	deployments := make([]*models.SysconfigDeployment, 0, len(records))
+	for _, rec := range records {
+		d := sysconfigDeployment(rec)
+		d.Settings = nil
+		deployments = append(deployments, d)
+	}
+	return deployments, nil
+}
+
+// Deployment returns a deployment with its settings. Password values are
+// masked.
+func (s *SysconfigService) Deployment(ctx context.Context, id int) (*models.SysconfigDeployment, error) {
+	rec, err := s.repo.GetDeployment(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if rec == nil {
+		return nil, ErrSysconfigDeploymentNotFound
+	}
+	d := sysconfigDeployment(rec)
+	if len(d.Settings) > 0 {
+		entries, err := s.entries(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			if _, ok := d.Settings[e.rec.Name]; ok && e.secret() {
+				d.Settings[e.rec.Name] = sysconfigSecretMask
+			}
+		}
+	}
+	return d, nil
+}
+
+// Restore brings the settings back to the values of a deployment. Settings
+// the deployment does not know, and read-only settings, are left alone.
+// The restored values go through the same validation as Update; the result
+// is not deployed until Deploy is called. It returns the changed settings.
+func (s *SysconfigService) Restore(ctx context.Context, id, userID int) ([]*models.SysconfigSetting, error) {
+	_, snap, err := s.readableDeployment(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := s.entries(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.Update(ctx, snap.restorable(entries), nil, userID)
+}
+
+// Rollback brings the settings back to the values of a deployment and
+// records the result as a new deployment, in one transaction. Settings the
+// deployment does not know, and read-only settings, are left alone. The
+// values go through the same validation as Update, so a value the schema
+// no longer accepts fails the whole rollback. Without comments the new
+// deployment is described as a rollback to id.
+func (s *SysconfigService) Rollback(ctx context.Context, id int, comments string, userID int) (*models.SysconfigRollback, error) {
+	_, snap, err := s.readableDeployment(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := s.entries(ctx)
+	if err != nil {
+		return nil, err
+	}
+	changes, err := planSysconfigChanges(entries, snap.restorable(entries), nil)
+	if err != nil {
+		return nil, err
+	}
+	data, err := sysconfigSnapshotData(entries, changes)
+	if err != nil {
+		return nil, err
+	}
+	comments = strings.TrimSpace(comments)
+	if comments == "" {
+		comments = fmt.Sprintf("Rollback to deployment #%d", id)
+	}
+	newID, err := s.repo.Deploy(ctx, changes, comments, data, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	deployment, err := s.Deployment(ctx, newID)
+	if err != nil {
+		return nil, err
+	}
+	deployment.Settings = nil
+	changed := []*models.SysconfigSetting{}
+	if len(changes) > 0 {
+		if changed, err = s.changedSettings(ctx, changes); err != nil {
+			return nil, err
+		}
+	}
+	return &models.SysconfigRollback{Deployment: deployment, Changed: changed}, nil
+}
+
+// DeploymentDiff lists the settings a deployment changed. It is compared
+// with the deployment against, or when against is 0 with the closest
+// earlier deployment GoatFlow can read; without one, with the defaults.
+// Password values are masked.
+func (s *SysconfigService) DeploymentDiff(ctx context.Context, id, against int) (*models.SysconfigDeploymentDiff, error) {
+	rec, snap, err := s.readableDeployment(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := s.entries(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var prev *repository.SysconfigDeploymentRecord
+	var before map[string]string
+	if against > 0 {
+		var prevSnap sysconfigSnapshot
+		if prev, prevSnap, err = s.readableDeployment(ctx, against); err != nil {
+			return nil, err
+		}
+		before = prevSnap.Settings
+	} else {
+		for cur := id; ; cur = prev.ID {
+			if prev, err = s.repo.PreviousDeployment(ctx, cur); err != nil {
+				return nil, err
+			}
+			if prev == nil {
+				break
+			}
+			if prevSnap, ok := decodeSysconfigSnapshot(prev.Snapshot); ok {
+				before = prevSnap.Settings
+				break
+			}
+		}
+	}
+
+	secret := map[string]bool{}
+	defaults := map[string]string{}
+	for _, e := range entries {
+		secret[e.rec.Name] = e.secret()
+		defaults[e.rec.Name] = e.rec.Default
+	}
+	if before == nil {
+		// Against the defaults only values that differ from them count;
+		// settings that no longer have a default show as added.
+		before = map[string]string{}
+		for name := range snap.Settings {
+			if d, ok := defaults[name]; ok {
+				before[name] = d
+			}
+		}
+	}
+
+	names := make([]string, 0, len(snap.Settings)+len(before))
+	for name := range snap.Settings {
+		names = append(names, name)
+	}
+	for name := range before {
+		if _, ok := snap.Settings[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	mask := func(name, v string, ok bool) *string {
+		if !ok {
+			return nil
+		}
+		if secret[name] {
+			v = sysconfigSecretMask
+		}
+		return &v
+	}
+	changes := []models.SysconfigSettingChange{}
+	for _, name := range names {
+		b, hadBefore := before[name]
+		a, hasAfter := snap.Settings[name]
+		ch := models.SysconfigSettingChange{Name: name, Before: mask(name, b, hadBefore), After: mask(name, a, hasAfter)}
+		switch {
+		case hadBefore && hasAfter:
+			if a == b {
+				continue
+			}
+			ch.Change = models.SysconfigChangeChanged
+		case hasAfter:
+			ch.Change = models.SysconfigChangeAdded
+		default:
+			ch.Change = models.SysconfigChangeRemoved
+		}
+		changes = append(changes, ch)
+	}
+
+	diff := &models.SysconfigDeploymentDiff{Deployment: sysconfigDeployment(rec), Changes: changes}
+	diff.Deployment.Settings = nil
+	if prev != nil {
+		diff.Previous = sysconfigDeployment(prev)
+		diff.Previous.Settings = nil
+	}
+	return diff, nil
+}
+
+// readableDeployment loads a deployment and decodes its snapshot.
+func (s *SysconfigService) readableDeployment(ctx context.Context, id int) (*repository.SysconfigDeploymentRecord, sysconfigSnapshot, error) {
+	rec, err := s.repo.GetDeployment(ctx, id)
+	if err != nil {
+		return nil, sysconfigSnapshot{}, err
+	}
+	if rec == nil {
+		return nil, sysconfigSnapshot{}, ErrSysconfigDeploymentNotFound
+	}
+	snap, ok := decodeSysconfigSnapshot(rec.Snapshot)
+	if !ok {
+		return nil, sysconfigSnapshot{}, ErrSysconfigDeploymentFormat
+	}
+	return rec, snap, nil
+}
+
+func sysconfigDeployment(rec *repository.SysconfigDeploymentRecord) *models.SysconfigDeployment {
+	d := &models.SysconfigDeployment{
+		ID:         rec.ID,
+		Comments:   rec.Comments,
+		CreateTime: rec.CreateTime,
+		CreateBy:   rec.CreateBy,
+	}
+	if snap, ok := decodeSysconfigSnapshot(rec.Snapshot); ok {
+		d.SettingCount = len(snap.Settings)
+		d.Settings = snap.Settings
+	}
+	return d
+}
+
+func decodeSysconfigSnapshot(data []byte) (sysconfigSnapshot, bool) {
+	var snap sysconfigSnapshot
+	if err := json.Unmarshal(data, &snap); err != nil || snap.Version != sysconfigSnapshotVersion {
+		return sysconfigSnapshot{}, false
+	}
+	return snap, true
+}
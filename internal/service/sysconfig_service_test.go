@@ -0,0 +1,375 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/testutil"
+)
+
+type sysconfigTestDefault struct {
+	id                               int
+	name, description, navigation    string
+	invisible, readonly, required    int
+	xmlContentParsed, effectiveValue string
+}
+
+func (d sysconfigTestDefault) insert(t *testing.T, db *sql.DB) {
+	t.Helper()
+	_, err := db.Exec(`INSERT INTO sysconfig_default (id, name, description, navigation, is_invisible, is_readonly,
+		is_required, is_valid, has_configlevel, user_modification_possible, user_modification_active,
+		xml_content_raw, xml_content_parsed, xml_filename, effective_value, is_dirty, exclusive_lock_guid,
+		create_time, create_by, change_time, change_by)
+		VALUES (?, ?, ?, ?, ?, ?, ?, 1, 0, 0, 0, '', ?, '', ?, 0, '', CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+		d.id, d.name, d.description, d.navigation, d.invisible, d.readonly, d.required, d.xmlContentParsed, d.effectiveValue)
+	require.NoError(t, err)
+}
+
+func newSysconfigTestService(t *testing.T) (*SysconfigService, *sql.DB) {
+	t.Helper()
+	db := testutil.MigratedDB(t)
+	// The settings below replace the seeded defaults.
+	for _, stmt := range []string{
+		`DELETE FROM sysconfig_modified`,
+		`DELETE FROM sysconfig_default`,
+		`INSERT INTO users (id, login, pw, first_name, last_name, valid_id, create_time, create_by, change_time, change_by) VALUES
+			(1, 'root@localhost', 'x', 'Admin', 'OTRS', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1),
+			(2, 'agent2', 'x', 'Agent', 'Two', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1),
+			(5, 'agent5', 'x', 'Agent', 'Five', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+	} {
+		_, err := db.Exec(stmt)
+		require.NoError(t, err, stmt)
+	}
+	for _, d := range []sysconfigTestDefault{
+		{1, "Ticket::Hook", "Ticket number prefix", "Core::Ticket", 0, 0, 1, `{"type":"string","max":10}`, "Ticket#"},
+		{2, "SessionMaxTime", "Session lifetime in seconds", "Core::Session", 0, 0, 0, `{"type":"integer","min":300,"max":86400}`, "57600"},
+		{3, "Ticket::Watcher", "Enable ticket watching", "Core::Ticket", 0, 0, 0, `{"type":"boolean"}`, "false"},
+		{4, "DefaultTheme", "Agent theme", "Frontend", 0, 0, 0, `{"type":"select","options":["Standard","Dark"]}`, "Standard"},
+		{5, "SystemID", "System identifier", "Core", 0, 1, 0, `{"type":"integer"}`, "10"},
+		{6, "SecureMode", "Hidden", "Core", 1, 0, 0, `{"type":"boolean"}`, "true"},
+		{7, "SendmailModule::AuthPassword", "SMTP password", "Core::Email", 0, 0, 0, `{"type":"password"}`, ""},
+	} {
+		d.insert(t, db)
+	}
+	_, err := db.Exec(`INSERT INTO sysconfig_modified (sysconfig_default_id, name, user_id, is_valid, user_modification_active,
+		effective_value, is_dirty, reset_to_default, create_time, create_by, change_time, change_by) VALUES
+		(2, 'SessionMaxTime', NULL, 1, 1, '3600', 0, 0, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1),
+		(4, 'DefaultTheme', 5, 1, 1, 'Dark', 0, 0, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`)
+	require.NoError(t, err)
+	return NewSysconfigService(db), db
+}
+
+func TestSysconfigService_List(t *testing.T) {
+	svc, _ := newSysconfigTestService(t)
+	ctx := context.Background()
+
+	all, err := svc.List(ctx, SysconfigFilter{})
+	require.NoError(t, err)
+	names := make([]string, len(all))
+	for i, st := range all {
+		names[i] = st.Name
+	}
+	assert.Equal(t, []string{"SystemID", "SendmailModule::AuthPassword", "SessionMaxTime", "Ticket::Hook", "Ticket::Watcher", "DefaultTheme"}, names,
+		"invisible settings are hidden")
+
+	session := all[2]
+	assert.Equal(t, "3600", session.Value)
+	assert.Equal(t, 3600, session.TypedValue)
+	assert.True(t, session.Modified)
+	theme := all[5]
+	assert.Equal(t, "Standard", theme.Value, "per-user overrides are not the global value")
+	assert.False(t, theme.Modified)
+	assert.Len(t, theme.Options, 2)
+	assert.Equal(t, false, all[4].TypedValue)
+
+	core, err := svc.List(ctx, SysconfigFilter{Navigation: "Core::Ticket"})
+	require.NoError(t, err)
+	assert.Len(t, core, 2)
+	nested, err := svc.List(ctx, SysconfigFilter{Navigation: "Core"})
+	require.NoError(t, err)
+	assert.Len(t, nested, 5, "a navigation filter includes its sub-groups")
+
+	found, err := svc.List(ctx, SysconfigFilter{Search: "lifetime"})
+	require.NoError(t, err)
+	require.Len(t, found, 1)
+	assert.Equal(t, "SessionMaxTime", found[0].Name)
+
+	modified, err := svc.List(ctx, SysconfigFilter{ModifiedOnly: true})
+	require.NoError(t, err)
+	require.Len(t, modified, 1)
+
+	navs, err := svc.Navigations(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Core", "Core::Email", "Core::Session", "Core::Ticket", "Frontend"}, navs)
+
+	_, err = svc.Get(ctx, "SecureMode")
+	assert.ErrorIs(t, err, ErrSysconfigNotFound)
+}
+
+func TestSysconfigService_Update(t *testing.T) {
+	svc, _ := newSysconfigTestService(t)
+	ctx := context.Background()
+
+	changed, err := svc.Update(ctx, map[string]string{
+		"Ticket::Watcher": "on",
+		"DefaultTheme":    "Dark",
+		"Ticket::Hook":    "Ticket#",
+	}, []string{"SessionMaxTime"}, 2)
+	require.NoError(t, err)
+	require.Len(t, changed, 3, "an unchanged value is not saved")
+
+	watcher, err := svc.Get(ctx, "Ticket::Watcher")
+	require.NoError(t, err)
+	assert.Equal(t, "true", watcher.Value)
+	assert.True(t, watcher.Modified)
+	assert.Equal(t, 2, watcher.ChangeBy)
+	session, err := svc.Get(ctx, "SessionMaxTime")
+	require.NoError(t, err)
+	assert.Equal(t, "57600", session.Value)
+	assert.False(t, session.Modified)
+
+	// Setting a value back to its default removes the override.
+	_, err = svc.Update(ctx, map[string]string{"DefaultTheme": "Standard"}, nil, 2)
+	require.NoError(t, err)
+	theme, err := svc.Get(ctx, "DefaultTheme")
+	require.NoError(t, err)
+	assert.False(t, theme.Modified)
+}
+
+func TestSysconfigService_UpdateValidation(t *testing.T) {
+	svc, _ := newSysconfigTestService(t)
+	ctx := context.Background()
+
+	_, err := svc.Update(ctx, map[string]string{
+		"SessionMaxTime":  "60",
+		"Ticket::Hook":    "",
+		"DefaultTheme":    "Neon",
+		"SystemID":        "12",
+		"Nope":            "1",
+		"Ticket::Watcher": "true",
+	}, nil, 2)
+	var verr *SysconfigValidationError
+	require.True(t, errors.As(err, &verr))
+	assert.ErrorIs(t, err, ErrSysconfigInvalid)
+	assert.Equal(t, map[string]string{
+		"SessionMaxTime": "must be at least 300",
+		"Ticket::Hook":   "is required",
+		"DefaultTheme":   "must be one of the allowed options",
+		"SystemID":       "is read-only",
+		"Nope":           "does not exist",
+	}, verr.Errors)
+
+	watcher, err := svc.Get(ctx, "Ticket::Watcher")
+	require.NoError(t, err)
+	assert.False(t, watcher.Modified, "a rejected update saves nothing")
+
+	_, err = svc.Update(ctx, map[string]string{"SessionMaxTime": "600"}, []string{"SessionMaxTime"}, 2)
+	require.True(t, errors.As(err, &verr))
+	assert.Contains(t, verr.Errors, "SessionMaxTime")
+}
+
+func TestSysconfigService_Secrets(t *testing.T) {
+	svc, _ := newSysconfigTestService(t)
+	ctx := context.Background()
+
+	_, err := svc.Update(ctx, map[string]string{"SendmailModule::AuthPassword": "hunter2"}, nil, 2)
+	require.NoError(t, err)
+
+	st, err := svc.Get(ctx, "SendmailModule::AuthPassword")
+	require.NoError(t, err)
+	assert.True(t, st.Secret)
+	assert.True(t, st.Modified)
+	assert.Empty(t, st.Value)
+
+	diff, err := svc.Diff(ctx)
+	require.NoError(t, err)
+	for _, entry := range diff {
+		assert.NotEqual(t, "hunter2", entry.Value)
+	}
+
+	d, err := svc.Deploy(ctx, "", 2)
+	require.NoError(t, err)
+	assert.Equal(t, sysconfigSecretMask, d.Settings["SendmailModule::AuthPassword"])
+}
+
+func TestSysconfigService_DiffDeployRestore(t *testing.T) {
+	svc, db := newSysconfigTestService(t)
+	ctx := context.Background()
+
+	diff, err := svc.Diff(ctx)
+	require.NoError(t, err)
+	require.Len(t, diff, 1)
+	assert.Equal(t, "SessionMaxTime", diff[0].Name)
+	assert.Equal(t, "57600", diff[0].Default)
+	assert.Equal(t, "3600", diff[0].Value)
+	assert.True(t, diff[0].Pending, "nothing is deployed yet")
+
+	first, err := svc.Deploy(ctx, " initial ", 2)
+	require.NoError(t, err)
+	assert.Equal(t, "initial", first.Comments)
+	assert.Equal(t, 7, first.SettingCount, "hidden and read-only settings are part of the snapshot")
+	assert.Equal(t, "3600", first.Settings["SessionMaxTime"])
+
+	diff, err = svc.Diff(ctx)
+	require.NoError(t, err)
+	require.Len(t, diff, 1)
+	assert.False(t, diff[0].Pending)
+	require.NotNil(t, diff[0].Deployed)
+
+	_, err = svc.Update(ctx, map[string]string{"Ticket::Watcher": "true"}, []string{"SessionMaxTime"}, 2)
+	require.NoError(t, err)
+	diff, err = svc.Diff(ctx)
+	require.NoError(t, err)
+	require.Len(t, diff, 2)
+	for _, entry := range diff {
+		assert.True(t, entry.Pending, entry.Name)
+	}
+
+	_, err = svc.Deploy(ctx, "watchers", 2)
+	require.NoError(t, err)
+	list, err := svc.Deployments(ctx, 0)
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	assert.Equal(t, "watchers", list[0].Comments, "newest first")
+	assert.Nil(t, list[0].Settings)
+
+	changed, err := svc.Restore(ctx, first.ID, 3)
+	require.NoError(t, err)
+	assert.Len(t, changed, 2)
+	session, err := svc.Get(ctx, "SessionMaxTime")
+	require.NoError(t, err)
+	assert.Equal(t, "3600", session.Value)
+	watcher, err := svc.Get(ctx, "Ticket::Watcher")
+	require.NoError(t, err)
+	assert.False(t, watcher.Modified)
+
+	_, err = svc.Deployment(ctx, 99)
+	assert.ErrorIs(t, err, ErrSysconfigDeploymentNotFound)
+
+	// Deployments written by OTRS hold Perl data and are listed, not restored.
+	_, err = db.Exec(`INSERT INTO sysconfig_deployment (comments, effective_value, create_time, create_by)
+		VALUES ('otrs', 'BQkDAAAAAQ==', CURRENT_TIMESTAMP, 1)`)
+	require.NoError(t, err)
+	list, err = svc.Deployments(ctx, 10)
+	require.NoError(t, err)
+	assert.Zero(t, list[0].SettingCount)
+	_, err = svc.Restore(ctx, list[0].ID, 2)
+	assert.ErrorIs(t, err, ErrSysconfigDeploymentFormat)
+}
+
+func TestSysconfigService_DeploymentDiff(t *testing.T) {
+	svc, db := newSysconfigTestService(t)
+	ctx := context.Background()
+
+	first, err := svc.Deploy(ctx, "initial", 2)
+	require.NoError(t, err)
+	diff, err := svc.DeploymentDiff(ctx, first.ID, 0)
+	require.NoError(t, err)
+	assert.Nil(t, diff.Previous, "the first deployment is compared with the defaults")
+	require.Len(t, diff.Changes, 1)
+	assert.Equal(t, "SessionMaxTime", diff.Changes[0].Name)
+	assert.Equal(t, "57600", *diff.Changes[0].Before)
+	assert.Equal(t, "3600", *diff.Changes[0].After)
+
+	// An OTRS deployment in between is skipped as a baseline.
+	_, err = db.Exec(`INSERT INTO sysconfig_deployment (comments, effective_value, create_time, create_by)
+		VALUES ('otrs', 'BQkDAAAAAQ==', CURRENT_TIMESTAMP, 1)`)
+	require.NoError(t, err)
+	_, err = svc.Update(ctx, map[string]string{
+		"Ticket::Watcher":              "true",
+		"SendmailModule::AuthPassword": "hunter2",
+	}, []string{"SessionMaxTime"}, 2)
+	require.NoError(t, err)
+	sysconfigTestDefault{8, "Ticket::Merge", "Allow merging", "Core::Ticket", 0, 0, 0, `{"type":"boolean"}`, "true"}.insert(t, db)
+	second, err := svc.Deploy(ctx, "watchers", 2)
+	require.NoError(t, err)
+
+	diff, err = svc.DeploymentDiff(ctx, second.ID, 0)
+	require.NoError(t, err)
+	require.NotNil(t, diff.Previous)
+	assert.Equal(t, first.ID, diff.Previous.ID)
+	assert.Nil(t, diff.Deployment.Settings)
+	changes := map[string]string{}
+	for _, ch := range diff.Changes {
+		changes[ch.Name] = ch.Change
+		if ch.Name == "SendmailModule::AuthPassword" {
+			assert.Equal(t, sysconfigSecretMask, *ch.After)
+		}
+		if ch.Name == "Ticket::Merge" {
+			assert.Nil(t, ch.Before)
+		}
+	}
+	assert.Equal(t, map[string]string{
+		"SessionMaxTime":               "changed",
+		"Ticket::Watcher":              "changed",
+		"SendmailModule::AuthPassword": "changed",
+		"Ticket::Merge":                "added",
+	}, changes)
+
+	diff, err = svc.DeploymentDiff(ctx, first.ID, second.ID)
+	require.NoError(t, err)
+	assert.Equal(t, second.ID, diff.Previous.ID)
+	assert.Len(t, diff.Changes, 4)
+	for _, ch := range diff.Changes {
+		if ch.Name == "Ticket::Merge" {
+			assert.Equal(t, "removed", ch.Change)
+		}
+	}
+
+	_, err = svc.DeploymentDiff(ctx, second.ID-1, 0)
+	assert.ErrorIs(t, err, ErrSysconfigDeploymentFormat)
+	_, err = svc.DeploymentDiff(ctx, first.ID, 99)
+	assert.ErrorIs(t, err, ErrSysconfigDeploymentNotFound)
+}
+
+func TestSysconfigService_Rollback(t *testing.T) {
+	svc, db := newSysconfigTestService(t)
+	ctx := context.Background()
+
+	first, err := svc.Deploy(ctx, "initial", 2)
+	require.NoError(t, err)
+	_, err = svc.Update(ctx, map[string]string{"Ticket::Watcher": "true", "DefaultTheme": "Dark"}, []string{"SessionMaxTime"}, 2)
+	require.NoError(t, err)
+	_, err = svc.Deploy(ctx, "watchers", 2)
+	require.NoError(t, err)
+
+	result, err := svc.Rollback(ctx, first.ID, "", 3)
+	require.NoError(t, err)
+	assert.Len(t, result.Changed, 3)
+	assert.Equal(t, fmt.Sprintf("Rollback to deployment #%d", first.ID), result.Deployment.Comments)
+	assert.Equal(t, 3, result.Deployment.CreateBy)
+
+	session, err := svc.Get(ctx, "SessionMaxTime")
+	require.NoError(t, err)
+	assert.Equal(t, "3600", session.Value)
+
+	// The rollback is recorded as a deployment equal to the one rolled back to.
+	diff, err := svc.DeploymentDiff(ctx, result.Deployment.ID, first.ID)
+	require.NoError(t, err)
+	assert.Empty(t, diff.Changes)
+	pending, err := svc.Diff(ctx)
+	require.NoError(t, err)
+	for _, entry := range pending {
+		assert.False(t, entry.Pending, entry.Name)
+	}
+
+	// A failed deployment insert leaves the settings untouched.
+	_, err = svc.Update(ctx, map[string]string{"Ticket::Watcher": "true"}, nil, 2)
+	require.NoError(t, err)
+	_, err = db.Exec(`CREATE TRIGGER no_deploy BEFORE INSERT ON sysconfig_deployment BEGIN SELECT RAISE(ABORT, 'disk full'); END`)
+	require.NoError(t, err)
+	_, err = svc.Rollback(ctx, first.ID, "again", 2)
+	require.Error(t, err)
+	watcher, err := svc.Get(ctx, "Ticket::Watcher")
+	require.NoError(t, err)
+	assert.Equal(t, "true", watcher.Value)
+
+	_, err = svc.Rollback(ctx, 99, "", 2)
+	assert.ErrorIs(t, err, ErrSysconfigDeploymentNotFound)
+}
@@ -0,0 +1,279 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/repository"
+)
+
+// maxAnnouncementLength is the length of system_announcement.message.
+const maxAnnouncementLength = 1000
+
+// defaultMaintenanceLoginMessage is shown to users turned away during
+// maintenance when neither the window nor the configuration has a message.
+const defaultMaintenanceLoginMessage = "The system is under maintenance. Please try again later."
+
+// Errors returned by SystemMaintenanceService.
+var (
+	ErrAnnouncementNotFound = errors.New("announcement not found")
+	ErrAnnouncementMessage  = fmt.Errorf("message is required and must be at most %d characters", maxAnnouncementLength)
+	ErrAnnouncementAudience = errors.New("audience must be one of agent, customer, all")
+	ErrAnnouncementSeverity = errors.New("severity must be one of info, warning, critical")
+	ErrAnnouncementWindow   = errors.New("stop_time must be after start_time")
+)
+
+// MaintenanceLoginError is returned by CheckLogin when a maintenance window
+// keeps the user from signing in. Message is meant for the login page.
+type MaintenanceLoginError struct {
+	Maintenance *models.SystemMaintenance
+	Message     string
+}
+
+func (e *MaintenanceLoginError) Error() string {
+	return e.Message
+}
+
+// MaintenanceDefaults are the configured fallbacks for maintenance windows.
+type MaintenanceDefaults struct {
+	// UpcomingMinutes is how long before its start a window is announced.
+	UpcomingMinutes int
+	// NotifyMessage is the banner text of windows without one.
+	NotifyMessage string
+	// LoginMessage is shown to users turned away at login when the window
+	// has no login message.
+	LoginMessage string
+}
+
+// SystemMaintenanceService combines scheduled maintenance windows, during
+// which only admins can sign in, with ad-hoc announcement banners.
+type SystemMaintenanceService struct {
+	windows       *repository.SystemMaintenanceRepository
+	announcements *repository.SystemAnnouncementRepository
+	access        *QueueAccessService
+	defaults      MaintenanceDefaults
+	now           func() time.Time
+}
+
+// NewSystemMaintenanceService creates a system maintenance service that
+// announces windows 30 minutes ahead.
+func NewSystemMaintenanceService(db *sql.DB) *SystemMaintenanceService {
+	return &SystemMaintenanceService{
+		windows:       repository.NewSystemMaintenanceRepository(db),
+		announcements: repository.NewSystemAnnouncementRepository(db),
+		access:        NewQueueAccessService(db),
+		defaults:      MaintenanceDefaults{UpcomingMinutes: 30},
+		now:           time.Now,
+	}
+}
+
+// SetDefaults sets the configured fallbacks. A zero UpcomingMinutes keeps
+// the current notice period.
+func (s *SystemMaintenanceService) SetDefaults(d MaintenanceDefaults) {
+	if d.UpcomingMinutes <= 0 {
+		d.UpcomingMinutes = s.defaults.UpcomingMinutes
+	}
+	s.defaults = d
+}
+
+// Status returns the active and upcoming maintenance windows and the
+// announcements shown to audience ("agent" or "customer"; empty for all).
+func (s *SystemMaintenanceService) Status(ctx context.Context, audience string) (*models.MaintenanceStatus, error) {
+	status := &models.MaintenanceStatus{Announcements: []*models.SystemAnnouncement{}}
+
+	active, err := s.windows.IsActive()
+	if err != nil {
+		return nil, fmt.Errorf("active maintenance: %w", err)
+	}
+	if active != nil {
+		status.Maintenance = true
+		status.Active = s.window(active)
+	}
+	upcoming, err := s.windows.IsComing(s.defaults.UpcomingMinutes)
+	if err != nil {
+		return nil, fmt.Errorf("upcoming maintenance: %w", err)
+	}
+	if upcoming != nil {
+		status.Upcoming = s.window(upcoming)
+	}
+
+	announcements, err := s.ActiveAnnouncements(ctx, audience)
+	if err != nil {
+		return nil, err
+	}
+	status.Announcements = append(status.Announcements, announcements...)
+	return status, nil
+}
+
+func (s *SystemMaintenanceService) window(m *models.SystemMaintenance) *models.MaintenanceWindow {
+	message := m.GetNotifyMessage()
+	if message == "" {
+		message = s.defaults.NotifyMessage
+	}
+	return &models.MaintenanceWindow{
+		ID:      m.ID,
+		Start:   time.Unix(m.StartDate, 0),
+		Stop:    time.Unix(m.StopDate, 0),
+		Message: message,
+	}
+}
+
+// Windows returns the valid maintenance windows that have not ended, the
+// next one first.
+func (s *SystemMaintenanceService) Windows(ctx context.Context) ([]*models.SystemMaintenance, error) {
+	records, err := s.windows.ListValid()
+	if err != nil {
+		return nil, fmt.Errorf("list maintenance windows: %w", err)
+	}
+	windows := make([]*models.SystemMaintenance, 0, len(records))
+	for _, m := range records {
+		if !m.IsPast() {
+			windows = append(windows, m)
+		}
+	}
+	sort.Slice(windows, func(i, j int) bool { return windows[i].StartDate < windows[j].StartDate })
+	return windows, nil
+}
+
+// CheckLogin returns a *MaintenanceLoginError when a maintenance window is
+// active and the account may not sign in: customers never may, agents only
+// when they are in the admin group.
+func (s *SystemMaintenanceService) CheckLogin(ctx context.Context, kind PasswordAccountType, userID int) error {
+	active, err := s.windows.IsActive()
+	if err != nil {
+		return fmt.Errorf("active maintenance: %w", err)
+	}
+	if active == nil {
+		return nil
+	}
+	if kind == PasswordAccountAgent {
+		isAdmin, err := s.access.IsAdmin(ctx, uint(userID))
+		if err != nil {
+			return err
+		}
+		if isAdmin {
+			return nil
+		}
+	}
+
+	message := ""
+	if active.ShowsLoginMessage() {
+		message = strings.TrimSpace(active.GetLoginMessage())
+	}
+	if message == "" {
+		message = s.defaults.LoginMessage
+	}
+	if message == "" {
+		message = defaultMaintenanceLoginMessage
+	}
+	return &MaintenanceLoginError{Maintenance: active, Message: message}
+}
+
+// ListAnnouncements returns every announcement, newest first.
+func (s *SystemMaintenanceService) ListAnnouncements(ctx context.Context) ([]*models.SystemAnnouncement, error) {
+	return s.announcements.List(ctx)
+}
+
+// ActiveAnnouncements returns the announcements shown now to audience
+// ("agent" or "customer"; empty for all), the most urgent first.
+func (s *SystemMaintenanceService) ActiveAnnouncements(ctx context.Context, audience string) ([]*models.SystemAnnouncement, error) {
+	all, err := s.announcements.ListActive(ctx, s.now())
+	if err != nil {
+		return nil, err
+	}
+	if audience == "" {
+		return all, nil
+	}
+	shown := make([]*models.SystemAnnouncement, 0, len(all))
+	for _, a := range all {
+		if a.ShownTo(audience) {
+			shown = append(shown, a)
+		}
+	}
+	return shown, nil
+}
+
+// GetAnnouncement returns an announcement by ID.
+func (s *SystemMaintenanceService) GetAnnouncement(ctx context.Context, id int) (*models.SystemAnnouncement, error) {
+	a, err := s.announcements.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if a == nil {
+		return nil, ErrAnnouncementNotFound
+	}
+	return a, nil
+}
+
+// CreateAnnouncement validates and stores a new announcement. The audience
+// defaults to all and the severity to info.
+func (s *SystemMaintenanceService) CreateAnnouncement(ctx context.Context, a *models.SystemAnnouncement, userID int) (*models.SystemAnnouncement, error) {
+	if err := normalizeAnnouncement(a); err != nil {
+		return nil, err
+	}
+	id, err := s.announcements.Create(ctx, a, userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.GetAnnouncement(ctx, id)
+}
+
+// UpdateAnnouncement validates and stores changes to an announcement.
+func (s *SystemMaintenanceService) UpdateAnnouncement(ctx context.Context, a *models.SystemAnnouncement, userID int) (*models.SystemAnnouncement, error) {
+	if err := normalizeAnnouncement(a); err != nil {
+		return nil, err
+	}
+	if err := s.announcements.Update(ctx, a, userID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrAnnouncementNotFound
+		}
+		return nil, err
+	}
+	return s.GetAnnouncement(ctx, a.ID)
+}
+
+// DeleteAnnouncement removes an announcement.
+func (s *SystemMaintenanceService) DeleteAnnouncement(ctx context.Context, id int) error {
+	if err := s.announcements.Delete(ctx, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrAnnouncementNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+func normalizeAnnouncement(a *models.SystemAnnouncement) error {
+	a.Message = strings.TrimSpace(a.Message)
+	if a.Message == "" || utf8.RuneCountInString(a.Message) > maxAnnouncementLength {
+		return ErrAnnouncementMessage
+	}
+	switch a.Audience {
+	case "":
+		a.Audience = models.AnnouncementAudienceAll
+	case models.AnnouncementAudienceAgent, models.AnnouncementAudienceCustomer, models.AnnouncementAudienceAll:
+	default:
+		return ErrAnnouncementAudience
+	}
+	switch a.Severity {
+	case "":
+		a.Severity = models.AnnouncementSeverityInfo
+	case models.AnnouncementSeverityInfo, models.AnnouncementSeverityWarning, models.AnnouncementSeverityCritical:
+	default:
+		return ErrAnnouncementSeverity
+	}
+	if a.StartTime != nil && a.StopTime != nil && !a.StopTime.After(*a.StartTime) {
+		return ErrAnnouncementWindow
+	}
+	if a.ValidID == 0 {
+		a.ValidID = 1
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/testutil"
+)
+
+func newSystemMaintenanceTestService(t *testing.T) (*SystemMaintenanceService, *sql.DB) {
+	t.Helper()
+	db := testutil.MigratedDB(t)
+
+	// Agent 1 is an admin, agent 2 is not
+	for _, stmt := range []string{
+		`INSERT INTO users (id, login, pw, first_name, last_name, valid_id, create_time, create_by, change_time, change_by)
+			VALUES (1, 'admin', 'x', 'A', 'Admin', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1),
+			(2, 'agent', 'x', 'B', 'Agent', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+		`INSERT INTO group_user (user_id, group_id, permission_key, create_time, create_by, change_time, change_by)
+			VALUES (1, 2, 'rw', CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1), (2, 1, 'rw', CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+	} {
+		_, err := db.Exec(stmt)
+		require.NoError(t, err, stmt)
+	}
+	return NewSystemMaintenanceService(db), db
+}
+
+func addMaintenanceWindow(t *testing.T, db *sql.DB, start, stop time.Time, loginMessage string) {
+	t.Helper()
+	now := time.Now()
+	_, err := db.Exec(`INSERT INTO system_maintenance (start_date, stop_date, comments, login_message, show_login_message,
+		notify_message, valid_id, create_time, create_by, change_time, change_by) VALUES (?, ?, 'upgrade', ?, 1, 'Upgrading', 1, ?, 1, ?, 1)`,
+		start.Unix(), stop.Unix(), loginMessage, now, now)
+	require.NoError(t, err)
+}
+
+func TestSystemMaintenanceService_CheckLogin(t *testing.T) {
+	svc, db := newSystemMaintenanceTestService(t)
+	ctx := context.Background()
+
+	require.NoError(t, svc.CheckLogin(ctx, PasswordAccountAgent, 2), "no window, no block")
+
+	now := time.Now()
+	addMaintenanceWindow(t, db, now.Add(-time.Minute), now.Add(time.Hour), "Back at noon")
+
+	assert.NoError(t, svc.CheckLogin(ctx, PasswordAccountAgent, 1), "admins sign in during maintenance")
+
+	err := svc.CheckLogin(ctx, PasswordAccountAgent, 2)
+	var blocked *MaintenanceLoginError
+	require.True(t, errors.As(err, &blocked))
+	assert.Equal(t, "Back at noon", blocked.Message)
+
+	err = svc.CheckLogin(ctx, PasswordAccountCustomer, 1)
+	require.True(t, errors.As(err, &blocked), "customers never sign in during maintenance")
+
+	_, err = db.Exec(`UPDATE system_maintenance SET show_login_message = 0`)
+	require.NoError(t, err)
+	err = svc.CheckLogin(ctx, PasswordAccountAgent, 2)
+	require.True(t, errors.As(err, &blocked))
+	assert.Equal(t, defaultMaintenanceLoginMessage, blocked.Message)
+
+	svc.SetDefaults(MaintenanceDefaults{LoginMessage: "Maintenance until 14:00"})
+	err = svc.CheckLogin(ctx, PasswordAccountAgent, 2)
+	require.True(t, errors.As(err, &blocked))
+	assert.Equal(t, "Maintenance until 14:00", blocked.Message)
+}
+
+func TestSystemMaintenanceService_Status(t *testing.T) {
+	svc, db := newSystemMaintenanceTestService(t)
+	ctx := context.Background()
+
+	status, err := svc.Status(ctx, "")
+	require.NoError(t, err)
+	assert.False(t, status.Maintenance)
+	assert.Nil(t, status.Active)
+	assert.NotNil(t, status.Announcements, "an empty list, not null")
+
+	now := time.Now()
+	addMaintenanceWindow(t, db, now.Add(10*time.Minute), now.Add(time.Hour), "")
+	status, err = svc.Status(ctx, "")
+	require.NoError(t, err)
+	assert.False(t, status.Maintenance)
+	require.NotNil(t, status.Upcoming)
+	assert.Equal(t, "Upgrading", status.Upcoming.Message)
+
+	svc.SetDefaults(MaintenanceDefaults{UpcomingMinutes: 5})
+	status, err = svc.Status(ctx, "")
+	require.NoError(t, err)
+	assert.Nil(t, status.Upcoming, "announced only within the notice period")
+
+	addMaintenanceWindow(t, db, now.Add(-time.Minute), now.Add(time.Minute), "")
+	for _, text := range []string{"Agents only", "Everyone"} {
+		audience := models.AnnouncementAudienceAgent
+		if text == "Everyone" {
+			audience = models.AnnouncementAudienceAll
+		}
+		_, err := svc.CreateAnnouncement(ctx, &models.SystemAnnouncement{Message: text, Audience: audience}, 1)
+		require.NoError(t, err)
+	}
+	status, err = svc.Status(ctx, models.AnnouncementAudienceCustomer)
+	require.NoError(t, err)
+	assert.True(t, status.Maintenance)
+	require.NotNil(t, status.Active)
+	require.Len(t, status.Announcements, 1)
+	assert.Equal(t, "Everyone", status.Announcements[0].Message)
+}
+
+func TestSystemMaintenanceService_Announcements(t *testing.T) {
+	svc, _ := newSystemMaintenanceTestService(t)
+	ctx := context.Background()
+	now := time.Now()
+	past, future := now.Add(-time.Hour), now.Add(time.Hour)
+
+	for _, tc := range []struct {
+		name string
+		in   models.SystemAnnouncement
+		want error
+	}{
+		{"empty message", models.SystemAnnouncement{Message: "  "}, ErrAnnouncementMessage},
+		{"long message", models.SystemAnnouncement{Message: strings.Repeat("x", 1001)}, ErrAnnouncementMessage},
+		{"audience", models.SystemAnnouncement{Message: "x", Audience: "admins"}, ErrAnnouncementAudience},
+		{"severity", models.SystemAnnouncement{Message: "x", Severity: "urgent"}, ErrAnnouncementSeverity},
+		{"window", models.SystemAnnouncement{Message: "x", StartTime: &future, StopTime: &past}, ErrAnnouncementWindow},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := svc.CreateAnnouncement(ctx, &tc.in, 1)
+			assert.ErrorIs(t, err, tc.want)
+		})
+	}
+
+	info, err := svc.CreateAnnouncement(ctx, &models.SystemAnnouncement{Message: " New portal "}, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "New portal", info.Message)
+	assert.Equal(t, models.AnnouncementAudienceAll, info.Audience)
+	assert.Equal(t, models.AnnouncementSeverityInfo, info.Severity)
+	assert.Equal(t, 1, info.ValidID)
+
+	critical, err := svc.CreateAnnouncement(ctx, &models.SystemAnnouncement{
+		Message: "Mail is delayed", Audience: models.AnnouncementAudienceCustomer, Severity: models.AnnouncementSeverityCritical,
+		StartTime: &past, StopTime: &future,
+	}, 1)
+	require.NoError(t, err)
+	_, err = svc.CreateAnnouncement(ctx, &models.SystemAnnouncement{Message: "Tomorrow", StartTime: &future}, 1)
+	require.NoError(t, err)
+
+	active, err := svc.ActiveAnnouncements(ctx, "")
+	require.NoError(t, err)
+	require.Len(t, active, 2, "announcements that have not started are hidden")
+	assert.Equal(t, critical.ID, active[0].ID, "the most urgent comes first")
+
+	agents, err := svc.ActiveAnnouncements(ctx, models.AnnouncementAudienceAgent)
+	require.NoError(t, err)
+	require.Len(t, agents, 1)
+	assert.Equal(t, info.ID, agents[0].ID)
+
+	critical.ValidID = 2
+	_, err = svc.UpdateAnnouncement(ctx, critical, 1)
+	require.NoError(t, err)
+	active, err = svc.ActiveAnnouncements(ctx, models.AnnouncementAudienceCustomer)
+	require.NoError(t, err)
+	require.Len(t, active, 1, "invalid announcements are hidden")
+
+	all, err := svc.ListAnnouncements(ctx)
+	require.NoError(t, err)
+	assert.Len(t, all, 3)
+
+	require.NoError(t, svc.DeleteAnnouncement(ctx, info.ID))
+	assert.ErrorIs(t, svc.DeleteAnnouncement(ctx, info.ID), ErrAnnouncementNotFound)
+	_, err = svc.UpdateAnnouncement(ctx, &models.SystemAnnouncement{ID: info.ID, Message: "x"}, 1)
+	assert.ErrorIs(t, err, ErrAnnouncementNotFound)
+	_, err = svc.GetAnnouncement(ctx, info.ID)
+	assert.ErrorIs(t, err, ErrAnnouncementNotFound)
+}
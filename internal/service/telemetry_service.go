@@ -0,0 +1,354 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/repository"
+	"github.com/goatkit/goatflow/internal/sysconfig"
+	"github.com/goatkit/goatflow/internal/telemetry"
+	"github.com/goatkit/goatflow/internal/version"
+)
+
+// Errors returned by TelemetryService.
+var (
+	ErrTelemetryInvalid        = errors.New("invalid telemetry settings")
+	ErrTelemetrySubmitDisabled = errors.New("telemetry submission is not enabled")
+	ErrTelemetryDays           = errors.New("days must be between 1 and 365")
+)
+
+const (
+	// TelemetryFlushInterval is how often Watch stores the usage counted
+	// by this instance and picks up setting changes.
+	TelemetryFlushInterval = 5 * time.Minute
+	// TelemetryDefaultDays is the period shown when none is requested.
+	TelemetryDefaultDays = 30
+	// TelemetryMaxDays caps the period of insights.
+	TelemetryMaxDays = 365
+)
+
+// TelemetryService manages the opt-in anonymous usage telemetry: it applies
+// the admin's setting to the collector, stores what this instance counted,
+// summarises the stored usage and submits daily reports when configured.
+// Days are UTC days.
+type TelemetryService struct {
+	repo   *repository.TelemetryRepository
+	db     *sql.DB
+	client *http.Client
+	now    func() time.Time
+}
+
+// NewTelemetryService creates a telemetry service.
+func NewTelemetryService(db *sql.DB) *TelemetryService {
+	return &TelemetryService{
+		repo:   repository.NewTelemetryRepository(db),
+		db:     db,
+		client: &http.Client{Timeout: 15 * time.Second},
+		now:    time.Now,
+	}
+}
+
+// Settings returns the telemetry settings.
+func (s *TelemetryService) Settings(ctx context.Context) (*sysconfig.TelemetrySettings, error) {
+	return sysconfig.LoadTelemetrySettings(s.db)
+}
+
+// UpdateSettings switches counting and submission on or off and sets the
+// endpoint reports are submitted to. Submitting requires counting and an
+// http or https endpoint. The installation gets its random ID when
+// telemetry is first enabled.
+func (s *TelemetryService) UpdateSettings(ctx context.Context, enabled, submit bool, endpoint string, userID int) (*sysconfig.TelemetrySettings, error) {
+	endpoint = strings.TrimSpace(endpoint)
+	if endpoint != "" {
+		u, err := url.Parse(endpoint)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return nil, fmt.Errorf("%w: endpoint must be an http or https URL", ErrTelemetryInvalid)
+		}
+	}
+	if submit && !enabled {
+		return nil, fmt.Errorf("%w: submitting requires telemetry to be enabled", ErrTelemetryInvalid)
+	}
+	if submit && endpoint == "" {
+		return nil, fmt.Errorf("%w: submitting requires an endpoint", ErrTelemetryInvalid)
+	}
+
+	settings, err := s.Settings(ctx)
+	if err != nil {
+		return nil, err
+	}
+	settings.Enabled, settings.Submit, settings.Endpoint = enabled, submit, endpoint
+	if enabled && settings.InstallID == "" {
+		settings.InstallID = uuid.NewString()
+	}
+	if err := sysconfig.SaveTelemetrySettings(s.db, settings, userID); err != nil {
+		return nil, err
+	}
+	telemetry.SetEnabled(enabled)
+	return settings, nil
+}
+
+// Sync switches this instance's counting on or off as configured.
+func (s *TelemetryService) Sync(ctx context.Context) error {
+	settings, err := s.Settings(ctx)
+	if err != nil {
+		return err
+	}
+	telemetry.SetEnabled(settings.Enabled)
+	return nil
+}
+
+// Flush stores the usage counted by this instance since the last flush
+// under today's date.
+func (s *TelemetryService) Flush(ctx context.Context) error {
+	drained := telemetry.Drain()
+	if len(drained) == 0 {
+		return nil
+	}
+	today := statDate(s.now().UTC())
+	usage := make([]models.TelemetryUsage, 0, len(drained))
+	for k, c := range drained {
+		usage = append(usage, models.TelemetryUsage{
+			Date:       today,
+			Kind:       k.Kind,
+			Name:       k.Name,
+			Calls:      c.Calls,
+			Errors:     c.Errors,
+			DurationMs: c.DurationMs,
+		})
+	}
+	return s.repo.Add(ctx, usage)
+}
+
+// Watch applies the setting and then flushes and re-reads it every
+// interval until ctx is done, flushing once more before it returns.
+func (s *TelemetryService) Watch(ctx context.Context, interval time.Duration) {
+	if err := s.Sync(ctx); err != nil {
+		log.Printf("telemetry: loading settings failed: %v", err)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			flushCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			if err := s.Flush(flushCtx); err != nil {
+				log.Printf("telemetry: final flush failed: %v", err)
+			}
+			cancel()
+			return
+		case <-ticker.C:
+		}
+		if err := s.Flush(ctx); err != nil {
+			log.Printf("telemetry: flush failed: %v", err)
+		}
+		if err := s.Sync(ctx); err != nil {
+			log.Printf("telemetry: loading settings failed: %v", err)
+		}
+	}
+}
+
+// Insights summarises the stored usage of the last days, today included,
+// after flushing this instance's counts.
+func (s *TelemetryService) Insights(ctx context.Context, days int) (*models.TelemetryInsights, error) {
+	if days == 0 {
+		days = TelemetryDefaultDays
+	}
+	if days < 1 || days > TelemetryMaxDays {
+		return nil, ErrTelemetryDays
+	}
+	if err := s.Flush(ctx); err != nil {
+		return nil, err
+	}
+	to := statDate(s.now().UTC())
+	from := to.AddDate(0, 0, -(days - 1))
+	usage, err := s.repo.Range(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	insights := &models.TelemetryInsights{
+		From:  from.Format("2006-01-02"),
+		To:    to.Format("2006-01-02"),
+		Daily: make([]models.TelemetryDay, 0),
+	}
+	insights.Totals, insights.Features, insights.Plugins = summarizeTelemetry(usage)
+	for _, u := range usage {
+		date := u.Date.Format("2006-01-02")
+		if n := len(insights.Daily); n == 0 || insights.Daily[n-1].Date != date {
+			insights.Daily = append(insights.Daily, models.TelemetryDay{Date: date})
+		}
+		day := &insights.Daily[len(insights.Daily)-1]
+		switch u.Kind {
+		case telemetry.KindRoute:
+			day.Requests += u.Calls
+			day.RequestErrors += u.Errors
+		case telemetry.KindPlugin:
+			day.PluginCalls += u.Calls
+			day.PluginFailures += u.Errors
+		}
+	}
+	return insights, nil
+}
+
+// Report builds the anonymous report of the given day, as it would be
+// submitted.
+func (s *TelemetryService) Report(ctx context.Context, date time.Time) (*models.TelemetryReport, error) {
+	settings, err := s.Settings(ctx)
+	if err != nil {
+		return nil, err
+	}
+	day := statDate(date)
+	usage, err := s.repo.Range(ctx, day, day)
+	if err != nil {
+		return nil, err
+	}
+	report := &models.TelemetryReport{
+		InstallID: settings.InstallID,
+		Version:   version.Version,
+		GoVersion: runtime.Version(),
+		Date:      day.Format("2006-01-02"),
+	}
+	report.Totals, report.Features, report.Plugins = summarizeTelemetry(usage)
+	return report, nil
+}
+
+// Submit sends yesterday's report to the configured endpoint and records
+// the outcome in the settings.
+func (s *TelemetryService) Submit(ctx context.Context) (*models.TelemetryReport, error) {
+	settings, err := s.Settings(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !settings.Enabled || !settings.Submit || settings.Endpoint == "" {
+		return nil, ErrTelemetrySubmitDisabled
+	}
+	report, err := s.Report(ctx, s.now().UTC().AddDate(0, 0, -1))
+	if err != nil {
+		return nil, err
+	}
+
+	sendErr := s.post(ctx, settings.Endpoint, report)
+	now := s.now()
+	settings.LastSubmitted, settings.LastError = &now, ""
+	if sendErr != nil {
+		settings.LastError = sendErr.Error()
+	}
+	if err := sysconfig.SaveTelemetrySettings(s.db, settings, 1); err != nil {
+		return nil, err
+	}
+	if sendErr != nil {
+		return nil, sendErr
+	}
+	return report, nil
+}
+
+func (s *TelemetryService) post(ctx context.Context, endpoint string, report *models.TelemetryReport) error {
+	body, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "GoatFlow/"+version.Version)
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("submit telemetry: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("submit telemetry: endpoint returned %s", resp.Status)
+	}
+	return nil
+}
+
+// Prune removes stored usage older than retentionDays.
+func (s *TelemetryService) Prune(ctx context.Context, retentionDays int) (int64, error) {
+	if retentionDays < 1 {
+		return 0, nil
+	}
+	return s.repo.DeleteBefore(ctx, statDate(s.now().UTC()).AddDate(0, 0, -retentionDays))
+}
+
+// summarizeTelemetry sums usage per route and plugin function, most used
+// first.
+func summarizeTelemetry(usage []models.TelemetryUsage) (models.TelemetryTotals, []models.TelemetryItem, []models.TelemetryItem) {
+	var totals models.TelemetryTotals
+	sums := map[string]map[string]*models.TelemetryUsage{
+		telemetry.KindRoute:  {},
+		telemetry.KindPlugin: {},
+	}
+	for _, u := range usage {
+		byName, ok := sums[u.Kind]
+		if !ok {
+			continue
+		}
+		sum := byName[u.Name]
+		if sum == nil {
+			sum = &models.TelemetryUsage{Kind: u.Kind, Name: u.Name}
+			byName[u.Name] = sum
+		}
+		sum.Calls += u.Calls
+		sum.Errors += u.Errors
+		sum.DurationMs += u.DurationMs
+		if u.Kind == telemetry.KindRoute {
+			totals.Requests += u.Calls
+			totals.RequestErrors += u.Errors
+		} else {
+			totals.PluginCalls += u.Calls
+			totals.PluginFailures += u.Errors
+		}
+	}
+	totals.ErrorRate = telemetryRate(totals.RequestErrors, totals.Requests)
+	totals.PluginErrorRate = telemetryRate(totals.PluginFailures, totals.PluginCalls)
+
+	items := func(byName map[string]*models.TelemetryUsage) []models.TelemetryItem {
+		list := make([]models.TelemetryItem, 0, len(byName))
+		for _, u := range byName {
+			item := models.TelemetryItem{
+				Name:      u.Name,
+				Calls:     u.Calls,
+				Errors:    u.Errors,
+				ErrorRate: telemetryRate(u.Errors, u.Calls),
+			}
+			if u.Calls > 0 {
+				item.AvgDurationMs = math.Round(float64(u.DurationMs)/float64(u.Calls)*10) / 10
+			}
+			list = append(list, item)
+		}
+		sort.Slice(list, func(i, j int) bool {
+			if list[i].Calls != list[j].Calls {
+				return list[i].Calls > list[j].Calls
+			}
+			return list[i].Name < list[j].Name
+		})
+		return list
+	}
+	return totals, items(sums[telemetry.KindRoute]), items(sums[telemetry.KindPlugin])
+}
+
+// telemetryRate returns errors as a percentage of calls.
+func telemetryRate(errs, calls int64) float64 {
+	if calls == 0 {
+		return 0
+	}
+	return math.Round(float64(errs)*100/float64(calls)*100) / 100
+}
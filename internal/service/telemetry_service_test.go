@@ -0,0 +1,112 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/telemetry"
+	"github.com/goatkit/goatflow/internal/testutil"
+)
+
+func TestTelemetryService(t *testing.T) {
+	db := testutil.UseMigratedDB(t)
+	t.Cleanup(func() { telemetry.SetEnabled(false) })
+	ctx := context.Background()
+
+	now := time.Date(2026, 10, 16, 9, 0, 0, 0, time.UTC)
+	svc := NewTelemetryService(db)
+	svc.now = func() time.Time { return now }
+
+	settings, err := svc.Settings(ctx)
+	require.NoError(t, err)
+	assert.False(t, settings.Enabled, "telemetry is opt-in")
+
+	_, err = svc.UpdateSettings(ctx, false, true, "https://telemetry.example.com", 1)
+	assert.ErrorIs(t, err, ErrTelemetryInvalid, "submitting requires counting")
+	_, err = svc.UpdateSettings(ctx, true, true, "", 1)
+	assert.ErrorIs(t, err, ErrTelemetryInvalid)
+	_, err = svc.UpdateSettings(ctx, true, false, "ftp://example.com", 1)
+	assert.ErrorIs(t, err, ErrTelemetryInvalid)
+	_, err = svc.Submit(ctx)
+	assert.ErrorIs(t, err, ErrTelemetrySubmitDisabled)
+
+	settings, err = svc.UpdateSettings(ctx, true, false, "", 1)
+	require.NoError(t, err)
+	assert.True(t, telemetry.Enabled())
+	assert.NotEmpty(t, settings.InstallID)
+	installID := settings.InstallID
+
+	// Yesterday's usage, stored by another instance
+	require.NoError(t, svc.repo.Add(ctx, []models.TelemetryUsage{
+		{Date: statDate(now.AddDate(0, 0, -1)), Kind: telemetry.KindRoute, Name: "GET /api/v1/tickets", Calls: 8, Errors: 2, DurationMs: 400},
+		{Date: statDate(now.AddDate(0, 0, -1)), Kind: telemetry.KindPlugin, Name: "jira-sync.sync", Calls: 2, DurationMs: 300},
+	}))
+	telemetry.RecordRoute("GET", "/api/v1/tickets", 200, 50*time.Millisecond)
+	telemetry.RecordRoute("POST", "/api/v1/tickets", 500, 10*time.Millisecond)
+	require.NoError(t, svc.Flush(ctx))
+	telemetry.RecordRoute("GET", "/api/v1/tickets", 200, 50*time.Millisecond)
+
+	insights, err := svc.Insights(ctx, 7)
+	require.NoError(t, err)
+	assert.Equal(t, "2026-10-10", insights.From)
+	assert.Equal(t, "2026-10-16", insights.To)
+	assert.Equal(t, int64(11), insights.Totals.Requests, "pending counts are flushed first")
+	assert.Equal(t, int64(3), insights.Totals.RequestErrors)
+	assert.Equal(t, 27.27, insights.Totals.ErrorRate)
+	require.Len(t, insights.Features, 2)
+	assert.Equal(t, models.TelemetryItem{Name: "GET /api/v1/tickets", Calls: 10, Errors: 2, ErrorRate: 20, AvgDurationMs: 50}, insights.Features[0])
+	require.Len(t, insights.Plugins, 1)
+	assert.Equal(t, 150.0, insights.Plugins[0].AvgDurationMs)
+	require.Len(t, insights.Daily, 2)
+	assert.Equal(t, models.TelemetryDay{Date: "2026-10-16", Requests: 3, RequestErrors: 1}, insights.Daily[1])
+
+	_, err = svc.Insights(ctx, 400)
+	assert.ErrorIs(t, err, ErrTelemetryDays)
+
+	var received models.TelemetryReport
+	status := http.StatusAccepted
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	_, err = svc.UpdateSettings(ctx, true, true, server.URL, 1)
+	require.NoError(t, err)
+	report, err := svc.Submit(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "2026-10-15", report.Date, "yesterday's report is submitted")
+	assert.Equal(t, installID, received.InstallID, "the installation keeps its ID")
+	assert.Equal(t, int64(8), received.Totals.Requests)
+	settings, err = svc.Settings(ctx)
+	require.NoError(t, err)
+	require.NotNil(t, settings.LastSubmitted)
+	assert.Empty(t, settings.LastError)
+
+	status = http.StatusInternalServerError
+	_, err = svc.Submit(ctx)
+	require.Error(t, err)
+	assert.False(t, errors.Is(err, ErrTelemetrySubmitDisabled))
+	settings, err = svc.Settings(ctx)
+	require.NoError(t, err)
+	assert.Contains(t, settings.LastError, "500")
+
+	svc.now = func() time.Time { return now.AddDate(0, 0, 1) }
+	n, err := svc.Prune(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), n, "usage older than a day is removed")
+
+	_, err = svc.UpdateSettings(ctx, false, false, "", 1)
+	require.NoError(t, err)
+	assert.False(t, telemetry.Enabled())
+}
@@ -0,0 +1,315 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/repository"
+)
+
+// defaultArchiveBatchSize is how many tickets an archive run handles per
+// policy and query.
+const defaultArchiveBatchSize = 500
+
+// Errors returned by TicketArchiveService.
+var (
+	ErrArchivePolicyNotFound = errors.New("archive policy not found")
+	ErrArchivePolicyName     = errors.New("name is required and must be at most 200 characters")
+	ErrArchivePolicyDays     = errors.New("archive_after_days must be at least 1")
+	ErrArchivePolicyPurge    = errors.New("purge_after_days must not be negative")
+	ErrArchiveTicketNotFound = errors.New("ticket not found")
+)
+
+// TicketIndexer indexes a ticket for search. Archived tickets are kept in
+// the archive partition of the index, so tickets are indexed again when
+// they are archived or restored.
+type TicketIndexer interface {
+	IndexTicket(ctx context.Context, ticket *models.Ticket) error
+}
+
+// TicketArchiveService applies archive policies to old tickets and
+// restores or purges archived ones. Archiving sets the ticket's
+// archive_flag, which keeps it out of default lists and searches, and may
+// move its article content to the archive tables.
+type TicketArchiveService struct {
+	repo      *repository.TicketArchiveRepository
+	tickets   *repository.TicketRepository
+	indexer   TicketIndexer
+	batchSize int
+	now       func() time.Time
+}
+
+// NewTicketArchiveService creates a ticket archive service without a
+// search indexer.
+func NewTicketArchiveService(db *sql.DB) *TicketArchiveService {
+	return &TicketArchiveService{
+		repo:      repository.NewTicketArchiveRepository(db),
+		tickets:   repository.NewTicketRepository(db),
+		batchSize: defaultArchiveBatchSize,
+		now:       time.Now,
+	}
+}
+
+// SetIndexer sets the search indexer told about archived and restored
+// tickets.
+func (s *TicketArchiveService) SetIndexer(indexer TicketIndexer) {
+	s.indexer = indexer
+}
+
+// SetBatchSize sets how many tickets a run handles per policy and query.
+// Values below 1 keep the current size.
+func (s *TicketArchiveService) SetBatchSize(n int) {
+	if n > 0 {
+		s.batchSize = n
+	}
+}
+
+// ListPolicies returns all archive policies.
+func (s *TicketArchiveService) ListPolicies(ctx context.Context) ([]*models.ArchivePolicy, error) {
+	return s.repo.ListPolicies(ctx)
+}
+
+// GetPolicy returns an archive policy by ID.
+func (s *TicketArchiveService) GetPolicy(ctx context.Context, id int) (*models.ArchivePolicy, error) {
+	p, err := s.repo.GetPolicy(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return nil, ErrArchivePolicyNotFound
+	}
+	return p, nil
+}
+
+// CreatePolicy validates and stores a new archive policy. The state types
+// default to closed.
+func (s *TicketArchiveService) CreatePolicy(ctx context.Context, p *models.ArchivePolicy, userID int) (*models.ArchivePolicy, error) {
+	if err := normalizeArchivePolicy(p); err != nil {
+		return nil, err
+	}
+	id, err := s.repo.CreatePolicy(ctx, p, userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.GetPolicy(ctx, id)
+}
+
+// UpdatePolicy validates and stores changes to an archive policy.
+func (s *TicketArchiveService) UpdatePolicy(ctx context.Context, p *models.ArchivePolicy, userID int) (*models.ArchivePolicy, error) {
+	if err := normalizeArchivePolicy(p); err != nil {
+		return nil, err
+	}
+	if err := s.repo.UpdatePolicy(ctx, p, userID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrArchivePolicyNotFound
+		}
+		return nil, err
+	}
+	return s.GetPolicy(ctx, p.ID)
+}
+
+// DeletePolicy removes an archive policy. Tickets it archived stay
+// archived.
+func (s *TicketArchiveService) DeletePolicy(ctx context.Context, id int) error {
+	if err := s.repo.DeletePolicy(ctx, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrArchivePolicyNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// Run applies the valid archive policies, or only the one with policyID,
+// and records the run. A dry run counts the tickets it would archive and
+// purge without changing them. Each policy handles at most one batch of
+// tickets per run; the scheduler picks up the rest next time.
+func (s *TicketArchiveService) Run(ctx context.Context, policyID *int, dryRun bool, userID int) (*models.ArchiveRun, error) {
+	var policies []*models.ArchivePolicy
+	if policyID != nil {
+		p, err := s.GetPolicy(ctx, *policyID)
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, p)
+	} else {
+		all, err := s.repo.ListPolicies(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range all {
+			if p.ValidID == 1 {
+				policies = append(policies, p)
+			}
+		}
+	}
+
+	run := &models.ArchiveRun{
+		PolicyID:  policyID,
+		DryRun:    dryRun,
+		Status:    models.ArchiveRunRunning,
+		StartedAt: s.now(),
+		CreateBy:  userID,
+	}
+	id, err := s.repo.CreateRun(ctx, run)
+	if err != nil {
+		return nil, err
+	}
+	run.ID = id
+
+	var runErr error
+	for _, p := range policies {
+		if runErr = s.applyPolicy(ctx, p, run, userID); runErr != nil {
+			runErr = fmt.Errorf("policy %q: %w", p.Name, runErr)
+			break
+		}
+	}
+
+	finished := s.now()
+	run.FinishedAt = &finished
+	run.Status = models.ArchiveRunSuccess
+	if runErr != nil {
+		run.Status = models.ArchiveRunFailed
+		run.Error = runErr.Error()
+	}
+	if err := s.repo.FinishRun(ctx, run); err != nil {
+		return nil, err
+	}
+	return run, runErr
+}
+
+func (s *TicketArchiveService) applyPolicy(ctx context.Context, p *models.ArchivePolicy, run *models.ArchiveRun, userID int) error {
+	now := s.now()
+	ids, err := s.repo.ArchiveCandidates(ctx, p, now, s.batchSize)
+	if err != nil {
+		return err
+	}
+	for _, ticketID := range ids {
+		if run.DryRun {
+			run.TicketsArchived++
+			continue
+		}
+		moved, err := s.repo.ArchiveTicket(ctx, ticketID, p.MoveArticles, &p.ID, &run.ID, userID)
+		if errors.Is(err, repository.ErrTicketAlreadyArchived) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("archive ticket %d: %w", ticketID, err)
+		}
+		s.reindex(ctx, ticketID)
+		run.TicketsArchived++
+		run.ArticlesMoved += moved
+	}
+
+	ids, err = s.repo.PurgeCandidates(ctx, p, now, s.batchSize)
+	if err != nil {
+		return err
+	}
+	for _, ticketID := range ids {
+		if run.DryRun {
+			run.TicketsPurged++
+			continue
+		}
+		if err := s.repo.PurgeTicket(ctx, ticketID); err != nil {
+			return fmt.Errorf("purge ticket %d: %w", ticketID, err)
+		}
+		run.TicketsPurged++
+	}
+	return nil
+}
+
+// Archive archives a single ticket regardless of the policies and, with
+// moveArticles, moves its article content to the archive tables.
+func (s *TicketArchiveService) Archive(ctx context.Context, ticketID int, moveArticles bool, userID int) (int, error) {
+	moved, err := s.repo.ArchiveTicket(ctx, ticketID, moveArticles, nil, nil, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrArchiveTicketNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+	s.reindex(ctx, ticketID)
+	return moved, nil
+}
+
+// Restore brings an archived ticket back, including any archived article
+// content, and returns the number of articles restored.
+func (s *TicketArchiveService) Restore(ctx context.Context, ticketID, userID int) (int, error) {
+	moved, err := s.repo.RestoreTicket(ctx, ticketID, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, ErrArchiveTicketNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+	s.reindex(ctx, ticketID)
+	return moved, nil
+}
+
+// reindex indexes a ticket again after its archive state changed. A
+// failure is logged; the ticket stays archived or restored and is fixed by
+// the next reindex.
+func (s *TicketArchiveService) reindex(ctx context.Context, ticketID int) {
+	if s.indexer == nil {
+		return
+	}
+	ticket, err := s.tickets.GetByID(uint(ticketID))
+	if err == nil {
+		err = s.indexer.IndexTicket(ctx, ticket)
+	}
+	if err != nil {
+		log.Printf("ticket archive: reindex ticket %d failed: %v", ticketID, err)
+	}
+}
+
+// Purge permanently deletes an archived ticket.
+func (s *TicketArchiveService) Purge(ctx context.Context, ticketID int) error {
+	err := s.repo.PurgeTicket(ctx, ticketID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrArchiveTicketNotFound
+	}
+	return err
+}
+
+// ListArchived returns archived tickets, most recently archived first.
+func (s *TicketArchiveService) ListArchived(ctx context.Context, limit, offset int) ([]*models.TicketArchive, error) {
+	return s.repo.ListArchived(ctx, limit, offset)
+}
+
+// ListRuns returns the most recent archive runs.
+func (s *TicketArchiveService) ListRuns(ctx context.Context, limit int) ([]*models.ArchiveRun, error) {
+	return s.repo.ListRuns(ctx, limit)
+}
+
+func normalizeArchivePolicy(p *models.ArchivePolicy) error {
+	p.Name = strings.TrimSpace(p.Name)
+	if p.Name == "" || len(p.Name) > 200 {
+		return ErrArchivePolicyName
+	}
+	types := make([]string, 0, len(p.StateTypes))
+	for _, t := range p.StateTypes {
+		if t = strings.TrimSpace(t); t != "" {
+			types = append(types, t)
+		}
+	}
+	if len(types) == 0 {
+		types = []string{"closed"}
+	}
+	p.StateTypes = types
+	if p.ArchiveAfterDays < 1 {
+		return ErrArchivePolicyDays
+	}
+	if p.PurgeAfterDays < 0 {
+		return ErrArchivePolicyPurge
+	}
+	if p.ValidID == 0 {
+		p.ValidID = 1
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/testutil"
+	"github.com/goatkit/goatflow/internal/zinc"
+)
+
+func newTicketArchiveTestService(t *testing.T) (*TicketArchiveService, *sql.DB) {
+	t.Helper()
+	db := testutil.UseMigratedDB(t)
+	_, err := db.Exec(`INSERT INTO users (id, login, pw, first_name, last_name, valid_id, create_time, create_by, change_time, change_by)
+		VALUES (1, 'root@localhost', 'x', 'Admin', 'OTRS', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`)
+	require.NoError(t, err)
+	return NewTicketArchiveService(db), db
+}
+
+func TestTicketArchiveService_PolicyValidation(t *testing.T) {
+	svc, _ := newTicketArchiveTestService(t)
+	ctx := context.Background()
+
+	_, err := svc.CreatePolicy(ctx, &models.ArchivePolicy{Name: " ", ArchiveAfterDays: 30}, 1)
+	assert.ErrorIs(t, err, ErrArchivePolicyName)
+	_, err = svc.CreatePolicy(ctx, &models.ArchivePolicy{Name: "Old", ArchiveAfterDays: 0}, 1)
+	assert.ErrorIs(t, err, ErrArchivePolicyDays)
+	_, err = svc.CreatePolicy(ctx, &models.ArchivePolicy{Name: "Old", ArchiveAfterDays: 30, PurgeAfterDays: -1}, 1)
+	assert.ErrorIs(t, err, ErrArchivePolicyPurge)
+
+	p, err := svc.CreatePolicy(ctx, &models.ArchivePolicy{Name: " Old ", ArchiveAfterDays: 30}, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "Old", p.Name)
+	assert.Equal(t, []string{"closed"}, p.StateTypes)
+	assert.Equal(t, 1, p.ValidID)
+
+	_, err = svc.UpdatePolicy(ctx, &models.ArchivePolicy{ID: p.ID + 100, Name: "Gone", ArchiveAfterDays: 30}, 1)
+	assert.ErrorIs(t, err, ErrArchivePolicyNotFound)
+	assert.ErrorIs(t, svc.DeletePolicy(ctx, p.ID+100), ErrArchivePolicyNotFound)
+	require.NoError(t, svc.DeletePolicy(ctx, p.ID))
+}
+
+func TestTicketArchiveService_Run(t *testing.T) {
+	svc, db := newTicketArchiveTestService(t)
+	old := time.Now().AddDate(0, 0, -100)
+	for id, stateID := range map[int]int{1: 4, 2: 2} { // closed, open
+		_, err := db.Exec(`INSERT INTO ticket (id, tn, title, queue_id, ticket_lock_id, user_id, responsible_user_id,
+			ticket_priority_id, ticket_state_id, timeout, until_time, escalation_time, escalation_update_time,
+			escalation_response_time, escalation_solution_time, create_time, create_by, change_time, change_by)
+			VALUES (?, ?, 'Printer', 1, 1, 1, 1, 3, ?, 0, 0, 0, 0, 0, 0, ?, 1, ?, 1)`,
+			id, time.Now().Format("20060102150405")+string(rune('0'+id)), stateID, old, old)
+		require.NoError(t, err)
+	}
+
+	ctx := context.Background()
+	_, err := svc.CreatePolicy(ctx, &models.ArchivePolicy{Name: "Closed", ArchiveAfterDays: 90}, 1)
+	require.NoError(t, err)
+	client := zinc.NewMockZincClient()
+	svc.SetIndexer(NewSearchService(client))
+	var tn string
+	require.NoError(t, db.QueryRow("SELECT tn FROM ticket WHERE id = 1").Scan(&tn))
+
+	run, err := svc.Run(ctx, nil, true, 1)
+	require.NoError(t, err)
+	assert.Equal(t, models.ArchiveRunSuccess, run.Status)
+	assert.True(t, run.DryRun)
+	assert.Equal(t, 1, run.TicketsArchived)
+
+	var flag int
+	require.NoError(t, db.QueryRow("SELECT archive_flag FROM ticket WHERE id = 1").Scan(&flag))
+	assert.Zero(t, flag, "dry runs change nothing")
+
+	run, err = svc.Run(ctx, nil, false, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 1, run.TicketsArchived)
+	require.NoError(t, db.QueryRow("SELECT archive_flag FROM ticket WHERE id = 1").Scan(&flag))
+	assert.Equal(t, 1, flag)
+
+	archived, err := svc.ListArchived(ctx, 10, 0)
+	require.NoError(t, err)
+	require.Len(t, archived, 1)
+	assert.Equal(t, 1, archived[0].TicketID)
+	_, err = client.GetDocument(ctx, "tickets_archive", tn)
+	assert.NoError(t, err, "an archived ticket is indexed into the archive partition")
+
+	_, err = svc.Restore(ctx, 1, 1)
+	require.NoError(t, err)
+	_, err = client.GetDocument(ctx, "tickets", tn)
+	assert.NoError(t, err, "a restored ticket is indexed into the live partition")
+	_, err = client.GetDocument(ctx, "tickets_archive", tn)
+	assert.Error(t, err)
+	_, err = svc.Restore(ctx, 99, 1)
+	assert.ErrorIs(t, err, ErrArchiveTicketNotFound)
+
+	runs, err := svc.ListRuns(ctx, 10)
+	require.NoError(t, err)
+	assert.Len(t, runs, 2)
+}
@@ -0,0 +1,399 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/services"
+)
+
+// Link object and state names as stored in link_object / link_state (OTRS compatible).
+const (
+	LinkObjectTicket = "Ticket"
+	LinkStateValid   = "Valid"
+)
+
+// Link type names as stored in link_type.
+const (
+	LinkTypeNormal      = "Normal"
+	LinkTypeParentChild = "ParentChild"
+	LinkTypeDuplicate   = "DuplicateOf"
+)
+
+// Errors returned by TicketLinkService.
+var (
+	ErrUnknownLinkType = errors.New("unknown link type")
+	ErrSelfLink        = errors.New("a ticket cannot be linked to itself")
+	ErrLinkExists      = errors.New("link already exists")
+	ErrLinkNotFound    = errors.New("link not found")
+	ErrLinkCycle       = errors.New("link would create a parent/child cycle")
+)
+
+// linkTypeAliases maps the API link type names to link_type rows.
+var linkTypeAliases = map[string]string{
+	"parent_child": LinkTypeParentChild,
+	"relates_to":   LinkTypeNormal,
+	"duplicates":   LinkTypeDuplicate,
+}
+
+// ResolveLinkType converts an API link type ("parent_child", "relates_to",
+// "duplicates") or a raw link_type name into the stored link_type name.
+func ResolveLinkType(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if t, ok := linkTypeAliases[strings.ToLower(name)]; ok {
+		return t, nil
+	}
+	for _, t := range linkTypeAliases {
+		if t == name {
+			return t, nil
+		}
+	}
+	return "", ErrUnknownLinkType
+}
+
+// LinkTypeAlias returns the API name for a stored link_type name.
+func LinkTypeAlias(typeName string) string {
+	for alias, t := range linkTypeAliases {
+		if t == typeName {
+			return alias
+		}
+	}
+	return typeName
+}
+
+// LinkedTicket describes one link between a ticket and another ticket as
+// seen from the ticket being viewed.
+type LinkedTicket struct {
+	TicketID     int    `json:"ticket_id"`
+	TicketNumber string `json:"ticket_number"`
+	Title        string `json:"title"`
+	Type         string `json:"type"`
+	// Role is the linked ticket's role: "parent", "child", "original",
+	// "duplicate" or "related".
+	Role      string    `json:"role"`
+	Direction string    `json:"direction"`
+	CreatedBy int       `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TicketLinkService manages typed, directed links between tickets stored in
+// the link_relation table.
+type TicketLinkService struct {
+	db *sql.DB
+}
+
+// NewTicketLinkService creates a new ticket link service.
+func NewTicketLinkService(db *sql.DB) *TicketLinkService {
+	return &TicketLinkService{db: db}
+}
+
+// ListLinks returns all valid links of a ticket in either direction.
+func (s *TicketLinkService) ListLinks(ctx context.Context, ticketID int) ([]LinkedTicket, error) {
+	links, err := s.listLinks(ctx, ticketID)
+	if err != nil {
+		return nil, err
+	}
+	return links, s.fillTicketDetails(ctx, links)
+}
+
+// ListLinksForUser returns the links of a ticket to tickets the agent can
+// read (ro on the linked ticket's queue). Links to other tickets are left
+// out, so their numbers and titles do not leak.
+func (s *TicketLinkService) ListLinksForUser(ctx context.Context, ticketID, userID int) ([]LinkedTicket, error) {
+	links, err := s.listLinks(ctx, ticketID)
+	if err != nil {
+		return nil, err
+	}
+
+	permSvc := services.NewPermissionService(s.db)
+	visible := make([]LinkedTicket, 0, len(links))
+	for _, l := range links {
+		canRead, err := permSvc.CanReadTicket(userID, int64(l.TicketID))
+		if err != nil {
+			return nil, err
+		}
+		if canRead {
+			visible = append(visible, l)
+		}
+	}
+	return visible, s.fillTicketDetails(ctx, visible)
+}
+
+// listLinks loads the links of a ticket without the linked tickets' details.
+func (s *TicketLinkService) listLinks(ctx context.Context, ticketID int) ([]LinkedTicket, error) {
+	key := strconv.Itoa(ticketID)
+	rows, err := s.db.QueryContext(ctx, database.ConvertPlaceholders(`
+		SELECT lr.source_key, lr.target_key, lt.name, lr.create_by, lr.create_time
+		FROM link_relation lr
+		JOIN link_object src_obj ON src_obj.id = lr.source_object_id
+		JOIN link_object dst_obj ON dst_obj.id = lr.target_object_id
+		JOIN link_type lt ON lt.id = lr.type_id
+		JOIN link_state ls ON ls.id = lr.state_id
+		WHERE src_obj.name = ? AND dst_obj.name = ? AND ls.name = ?
+		  AND (lr.source_key = ? OR lr.target_key = ?)
+		ORDER BY lr.create_time
+	`), LinkObjectTicket, LinkObjectTicket, LinkStateValid, key, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query ticket links: %w", err)
+	}
+	defer rows.Close()
+
+	links := make([]LinkedTicket, 0)
+	for rows.Next() {
+		var sourceKey, targetKey, typeName string
+		var l LinkedTicket
+		if err := rows.Scan(&sourceKey, &targetKey, &typeName, &l.CreatedBy, &l.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan ticket link: %w", err)
+		}
+		otherKey := targetKey
+		l.Direction = "outbound"
+		if sourceKey != key {
+			otherKey = sourceKey
+			l.Direction = "inbound"
+		}
+		otherID, err := strconv.Atoi(otherKey)
+		if err != nil {
+			continue
+		}
+		l.TicketID = otherID
+		l.Type = LinkTypeAlias(typeName)
+		l.Role = linkRole(typeName, l.Direction)
+		links = append(links, l)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return links, nil
+}
+
+// CreateLink links sourceID to targetID. For parent/child links the source is
+// the parent; for duplicate links the source duplicates the target.
+func (s *TicketLinkService) CreateLink(ctx context.Context, sourceID, targetID int, linkType string, userID int) error {
+	typeName, err := ResolveLinkType(linkType)
+	if err != nil {
+		return err
+	}
+	if sourceID == targetID {
+		return ErrSelfLink
+	}
+
+	exists, err := s.linkExists(ctx, sourceID, targetID, typeName)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return ErrLinkExists
+	}
+	if typeName != LinkTypeParentChild {
+		// A reverse relates-to or duplicate link would be redundant
+		if exists, err = s.linkExists(ctx, targetID, sourceID, typeName); err != nil {
+			return err
+		}
+		if exists {
+			return ErrLinkExists
+		}
+	}
+
+	if typeName == LinkTypeParentChild {
+		cycle, err := s.isDescendant(ctx, targetID, sourceID)
+		if err != nil {
+			return err
+		}
+		if cycle {
+			return ErrLinkCycle
+		}
+	}
+
+	result, err := s.db.ExecContext(ctx, database.ConvertPlaceholders(`
+		INSERT INTO link_relation
+			(source_object_id, source_key, target_object_id, target_key, type_id, state_id, create_time, create_by)
+		SELECT so.id, ?, dobj.id, ?, lt.id, ls.id, CURRENT_TIMESTAMP, ?
+		FROM link_object so, link_object dobj, link_type lt, link_state ls
+		WHERE so.name = ? AND dobj.name = ? AND lt.name = ? AND ls.name = ?
+	`), strconv.Itoa(sourceID), strconv.Itoa(targetID), userID,
+		LinkObjectTicket, LinkObjectTicket, typeName, LinkStateValid)
+	if err != nil {
+		return fmt.Errorf("failed to create link: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("link type %q is not configured: %w", typeName, ErrUnknownLinkType)
+	}
+	return nil
+}
+
+// DeleteLink removes a link between two tickets. Relates-to and duplicate
+// links are matched in either direction; parent/child only as given.
+func (s *TicketLinkService) DeleteLink(ctx context.Context, sourceID, targetID int, linkType string) error {
+	typeName, err := ResolveLinkType(linkType)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		DELETE FROM link_relation
+		WHERE source_object_id = (SELECT id FROM link_object WHERE name = ?)
+		  AND target_object_id = (SELECT id FROM link_object WHERE name = ?)
+		  AND type_id = (SELECT id FROM link_type WHERE name = ?)
+		  AND ((source_key = ? AND target_key = ?)`
+	args := []interface{}{LinkObjectTicket, LinkObjectTicket, typeName, strconv.Itoa(sourceID), strconv.Itoa(targetID)}
+	if typeName != LinkTypeParentChild {
+		query += ` OR (source_key = ? AND target_key = ?)`
+		args = append(args, strconv.Itoa(targetID), strconv.Itoa(sourceID))
+	}
+	query += `)`
+
+	result, err := s.db.ExecContext(ctx, database.ConvertPlaceholders(query), args...)
+	if err != nil {
+		return fmt.Errorf("failed to delete link: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return ErrLinkNotFound
+	}
+	return nil
+}
+
+// linkExists reports whether an exact directed link exists.
+func (s *TicketLinkService) linkExists(ctx context.Context, sourceID, targetID int, typeName string) (bool, error) {
+	var count int
+	err := s.db.QueryRowContext(ctx, database.ConvertPlaceholders(`
+		SELECT COUNT(*)
+		FROM link_relation lr
+		JOIN link_object src_obj ON src_obj.id = lr.source_object_id
+		JOIN link_object dst_obj ON dst_obj.id = lr.target_object_id
+		JOIN link_type lt ON lt.id = lr.type_id
+		WHERE src_obj.name = ? AND dst_obj.name = ? AND lt.name = ?
+		  AND lr.source_key = ? AND lr.target_key = ?
+	`), LinkObjectTicket, LinkObjectTicket, typeName, strconv.Itoa(sourceID), strconv.Itoa(targetID)).Scan(&count)
+	if err != nil {
+		return false, fmt.Errorf("failed to check link: %w", err)
+	}
+	return count > 0, nil
+}
+
+// isDescendant reports whether candidateID is rootID itself or is reachable
+// from rootID by following parent -> child links.
+func (s *TicketLinkService) isDescendant(ctx context.Context, rootID, candidateID int) (bool, error) {
+	return walkChildren(rootID, candidateID, func(parent int) ([]int, error) {
+		return s.childIDs(ctx, parent)
+	})
+}
+
+// walkChildren does a breadth-first walk over the parent/child graph.
+func walkChildren(rootID, candidateID int, children func(int) ([]int, error)) (bool, error) {
+	seen := map[int]bool{rootID: true}
+	queue := []int{rootID}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		if current == candidateID {
+			return true, nil
+		}
+		next, err := children(current)
+		if err != nil {
+			return false, err
+		}
+		for _, id := range next {
+			if !seen[id] {
+				seen[id] = true
+				queue = append(queue, id)
+			}
+		}
+	}
+	return false, nil
+}
+
+func (s *TicketLinkService) childIDs(ctx context.Context, parentID int) ([]int, error) {
+	rows, err := s.db.QueryContext(ctx, database.ConvertPlaceholders(`
+		SELECT lr.target_key
+		FROM link_relation lr
+		JOIN link_object src_obj ON src_obj.id = lr.source_object_id
+		JOIN link_object dst_obj ON dst_obj.id = lr.target_object_id
+		JOIN link_type lt ON lt.id = lr.type_id
+		WHERE src_obj.name = ? AND dst_obj.name = ? AND lt.name = ?
+		  AND lr.source_key = ?
+	`), LinkObjectTicket, LinkObjectTicket, LinkTypeParentChild, strconv.Itoa(parentID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to query child links: %w", err)
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		if id, err := strconv.Atoi(key); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids, rows.Err()
+}
+
+// fillTicketDetails loads ticket number and title for each linked ticket.
+func (s *TicketLinkService) fillTicketDetails(ctx context.Context, links []LinkedTicket) error {
+	if len(links) == 0 {
+		return nil
+	}
+	placeholders := make([]string, len(links))
+	args := make([]interface{}, len(links))
+	for i, l := range links {
+		placeholders[i] = "?"
+		args[i] = l.TicketID
+	}
+
+	rows, err := s.db.QueryContext(ctx, database.ConvertPlaceholders(
+		"SELECT id, tn, title FROM ticket WHERE id IN ("+strings.Join(placeholders, ",")+")",
+	), args...)
+	if err != nil {
+		return fmt.Errorf("failed to load linked tickets: %w", err)
+	}
+	defer rows.Close()
+
+	type ticketInfo struct{ tn, title string }
+	info := make(map[int]ticketInfo, len(links))
+	for rows.Next() {
+		var id int
+		var tn string
+		var title sql.NullString
+		if err := rows.Scan(&id, &tn, &title); err != nil {
+			return err
+		}
+		info[id] = ticketInfo{tn: tn, title: title.String}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for i := range links {
+		if t, ok := info[links[i].TicketID]; ok {
+			links[i].TicketNumber = t.tn
+			links[i].Title = t.title
+		}
+	}
+	return nil
+}
+
+// linkRole describes the linked ticket's role relative to the viewed ticket.
+func linkRole(typeName, direction string) string {
+	switch typeName {
+	case LinkTypeParentChild:
+		if direction == "outbound" {
+			return "child"
+		}
+		return "parent"
+	case LinkTypeDuplicate:
+		if direction == "outbound" {
+			return "original"
+		}
+		return "duplicate"
+	default:
+		return "related"
+	}
+}
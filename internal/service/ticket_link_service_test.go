@@ -0,0 +1,117 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/testutil"
+)
+
+func TestResolveLinkType(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"parent_child", LinkTypeParentChild},
+		{"Relates_To", LinkTypeNormal},
+		{" duplicates ", LinkTypeDuplicate},
+		{"ParentChild", LinkTypeParentChild},
+		{"Normal", LinkTypeNormal},
+	}
+	for _, tt := range tests {
+		got, err := ResolveLinkType(tt.input)
+		require.NoError(t, err, tt.input)
+		assert.Equal(t, tt.want, got)
+	}
+
+	_, err := ResolveLinkType("blocks")
+	assert.ErrorIs(t, err, ErrUnknownLinkType)
+	_, err = ResolveLinkType("")
+	assert.ErrorIs(t, err, ErrUnknownLinkType)
+}
+
+func TestLinkTypeAlias(t *testing.T) {
+	assert.Equal(t, "parent_child", LinkTypeAlias(LinkTypeParentChild))
+	assert.Equal(t, "relates_to", LinkTypeAlias(LinkTypeNormal))
+	assert.Equal(t, "duplicates", LinkTypeAlias(LinkTypeDuplicate))
+	assert.Equal(t, "DependsOn", LinkTypeAlias("DependsOn"))
+}
+
+func TestLinkRole(t *testing.T) {
+	assert.Equal(t, "child", linkRole(LinkTypeParentChild, "outbound"))
+	assert.Equal(t, "parent", linkRole(LinkTypeParentChild, "inbound"))
+	assert.Equal(t, "original", linkRole(LinkTypeDuplicate, "outbound"))
+	assert.Equal(t, "duplicate", linkRole(LinkTypeDuplicate, "inbound"))
+	assert.Equal(t, "related", linkRole(LinkTypeNormal, "inbound"))
+}
+
+func TestWalkChildren(t *testing.T) {
+	// 1 -> 2 -> 3, 2 -> 4, 5 isolated, 4 -> 2 (pre-existing loop must not hang)
+	graph := map[int][]int{1: {2}, 2: {3, 4}, 4: {2}}
+	children := func(id int) ([]int, error) { return graph[id], nil }
+
+	found, err := walkChildren(1, 3, children)
+	require.NoError(t, err)
+	assert.True(t, found, "3 is a grandchild of 1")
+
+	found, err = walkChildren(1, 1, children)
+	require.NoError(t, err)
+	assert.True(t, found, "a ticket is its own descendant for cycle purposes")
+
+	found, err = walkChildren(3, 1, children)
+	require.NoError(t, err)
+	assert.False(t, found, "1 is an ancestor, not a descendant, of 3")
+
+	found, err = walkChildren(1, 5, children)
+	require.NoError(t, err)
+	assert.False(t, found)
+}
+
+func TestListLinksForUser_HidesUnreadableTickets(t *testing.T) {
+	db := testutil.UseMigratedDB(t)
+	for _, stmt := range []string{
+		`INSERT INTO link_object (id, name) VALUES (1, 'Ticket')`,
+		`INSERT INTO link_type (id, name, valid_id, create_time, create_by, change_time, change_by)
+			VALUES (1, 'Normal', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+		`INSERT INTO link_state (id, name, valid_id, create_time, create_by, change_time, change_by)
+			VALUES (1, 'Valid', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+		`INSERT INTO groups (id, name, valid_id, create_time, create_by, change_time, change_by)
+			VALUES (11, 'secret', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+		`UPDATE queue SET group_id = CASE WHEN id = 2 THEN 11 ELSE 1 END`,
+		`INSERT INTO group_user (user_id, group_id, permission_key, create_time, create_by, change_time, change_by)
+			VALUES (5, 1, 'ro', CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+		`INSERT INTO link_relation VALUES (1, '1', 1, '2', 1, 1, CURRENT_TIMESTAMP, 1)`,
+		`INSERT INTO link_relation VALUES (1, '3', 1, '1', 1, 1, CURRENT_TIMESTAMP, 1)`,
+	} {
+		_, err := db.Exec(stmt)
+		require.NoError(t, err, stmt)
+	}
+	for _, ticket := range []struct {
+		id      int
+		title   string
+		queueID int
+	}{{1, "Viewed", 1}, {2, "Readable", 1}, {3, "Secret", 2}} {
+		_, err := db.Exec(`INSERT INTO ticket (id, tn, title, queue_id, ticket_lock_id, user_id, responsible_user_id,
+			ticket_priority_id, ticket_state_id, timeout, until_time, escalation_time, escalation_update_time,
+			escalation_response_time, escalation_solution_time, archive_flag, create_time, create_by, change_time, change_by)
+			VALUES (?, ?, ?, ?, 1, 1, 1, 3, 1, 0, 0, 0, 0, 0, 0, 0, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+			ticket.id, fmt.Sprintf("%d", 1000+ticket.id), ticket.title, ticket.queueID)
+		require.NoError(t, err)
+	}
+
+	svc := NewTicketLinkService(db)
+	all, err := svc.ListLinks(context.Background(), 1)
+	require.NoError(t, err)
+	assert.Len(t, all, 2)
+
+	links, err := svc.ListLinksForUser(context.Background(), 1, 5)
+	require.NoError(t, err)
+	require.Len(t, links, 1, "the link to the ticket in an unreadable queue must be left out")
+	assert.Equal(t, 2, links[0].TicketID)
+	assert.Equal(t, "1002", links[0].TicketNumber)
+	assert.Equal(t, "Readable", links[0].Title)
+}
@@ -0,0 +1,295 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/goatkit/goatflow/internal/database"
+)
+
+// Lock states as stored in ticket.ticket_lock_id (OTRS ticket_lock_type).
+const (
+	TicketLockUnlocked = 1
+	TicketLockLocked   = 2
+	TicketLockTmp      = 3
+)
+
+// DefaultTicketLockTTL is how long a lock lasts without being refreshed when
+// the ticket's queue has no unlock_timeout.
+const DefaultTicketLockTTL = 15 * time.Minute
+
+// Errors returned by TicketLockService.
+var (
+	ErrLockTicketNotFound  = errors.New("ticket not found")
+	ErrTicketLockedByOther = errors.New("ticket is locked by another agent")
+	ErrTicketNotLocked     = errors.New("ticket is not locked")
+	ErrLockTakeoverDenied  = errors.New("owner permission on the ticket's queue is required to take over a lock")
+)
+
+// TicketLock is the lock state of a ticket. An expired lock is reported as
+// unlocked even before the cleanup task has reset it.
+type TicketLock struct {
+	TicketID  int        `json:"ticket_id"`
+	QueueID   int        `json:"queue_id"`
+	Locked    bool       `json:"locked"`
+	OwnerID   int        `json:"owner_id"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// TicketLockResult describes what a Lock call changed.
+type TicketLockResult struct {
+	TicketLock
+	// PreviousOwnerID is the agent whose live lock was taken over, or 0.
+	PreviousOwnerID int `json:"previous_owner_id,omitempty"`
+	// Refreshed is true when the caller already held the lock.
+	Refreshed bool `json:"refreshed"`
+}
+
+// TicketLockService locks tickets for a single agent with a TTL, so two
+// agents do not reply to the same ticket at once. Locks use the OTRS
+// columns: ticket_lock_id, user_id as the holder and timeout as the Unix time
+// the lock was taken or last refreshed.
+type TicketLockService struct {
+	db  *sql.DB
+	ttl time.Duration
+	now func() time.Time
+	// canOverride reports whether an agent may take over or release other
+	// agents' locks in a queue.
+	canOverride func(ctx context.Context, userID, queueID int) (bool, error)
+}
+
+// NewTicketLockService creates a lock service. A ttl of zero uses
+// DefaultTicketLockTTL.
+func NewTicketLockService(db *sql.DB, ttl time.Duration) *TicketLockService {
+	if ttl <= 0 {
+		ttl = DefaultTicketLockTTL
+	}
+	s := &TicketLockService{db: db, ttl: ttl, now: time.Now}
+	s.canOverride = func(ctx context.Context, userID, queueID int) (bool, error) {
+		access := NewQueueAccessService(db)
+		if isAdmin, err := access.IsAdmin(ctx, uint(userID)); err != nil || isAdmin {
+			return isAdmin, err
+		}
+		return access.HasQueueAccess(ctx, uint(userID), uint(queueID), "owner")
+	}
+	return s
+}
+
+// lockRow is the raw lock state read from the ticket and its queue.
+type lockRow struct {
+	queueID  int
+	lockID   int
+	ownerID  int
+	lockedAt int64
+	queueTTL int // queue.unlock_timeout in minutes; 0 means the service default
+}
+
+func (s *TicketLockService) load(ctx context.Context, ticketID int) (*lockRow, error) {
+	var r lockRow
+	err := s.db.QueryRowContext(ctx, database.ConvertPlaceholders(`
+		SELECT t.queue_id, t.ticket_lock_id, COALESCE(t.user_id, 0), COALESCE(t.timeout, 0), COALESCE(q.unlock_timeout, 0)
+		FROM ticket t
+		JOIN queue q ON q.id = t.queue_id
+		WHERE t.id = ?`), ticketID).Scan(&r.queueID, &r.lockID, &r.ownerID, &r.lockedAt, &r.queueTTL)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrLockTicketNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("load ticket lock: %w", err)
+	}
+	return &r, nil
+}
+
+func (s *TicketLockService) ttlFor(r *lockRow) time.Duration {
+	if r.queueTTL > 0 {
+		return time.Duration(r.queueTTL) * time.Minute
+	}
+	return s.ttl
+}
+
+// held reports whether the row carries a lock that has not expired.
+func (s *TicketLockService) held(r *lockRow) bool {
+	if r.lockID == TicketLockUnlocked || r.ownerID <= 0 {
+		return false
+	}
+	return s.now().Before(time.Unix(r.lockedAt, 0).Add(s.ttlFor(r)))
+}
+
+func (s *TicketLockService) state(ticketID int, r *lockRow) TicketLock {
+	l := TicketLock{TicketID: ticketID, QueueID: r.queueID}
+	if s.held(r) {
+		expires := time.Unix(r.lockedAt, 0).Add(s.ttlFor(r)).UTC()
+		l.Locked = true
+		l.OwnerID = r.ownerID
+		l.ExpiresAt = &expires
+	}
+	return l
+}
+
+// Get returns the ticket's current lock state.
+func (s *TicketLockService) Get(ctx context.Context, ticketID int) (*TicketLock, error) {
+	r, err := s.load(ctx, ticketID)
+	if err != nil {
+		return nil, err
+	}
+	l := s.state(ticketID, r)
+	return &l, nil
+}
+
+// Lock locks the ticket for userID, or refreshes the TTL when userID already
+// holds it. A live lock held by another agent is only taken over when
+// takeover is set and userID has owner permission on the ticket's queue.
+// Locking also makes userID the ticket owner, as in OTRS.
+func (s *TicketLockService) Lock(ctx context.Context, ticketID, userID int, takeover bool) (*TicketLockResult, error) {
+	r, err := s.load(ctx, ticketID)
+	if err != nil {
+		return nil, err
+	}
+	result := &TicketLockResult{}
+	if s.held(r) {
+		switch {
+		case r.ownerID == userID:
+			result.Refreshed = true
+		case !takeover:
+			return nil, ErrTicketLockedByOther
+		default:
+			ok, err := s.canOverride(ctx, userID, r.queueID)
+			if err != nil {
+				return nil, fmt.Errorf("check takeover permission: %w", err)
+			}
+			if !ok {
+				return nil, ErrLockTakeoverDenied
+			}
+			result.PreviousOwnerID = r.ownerID
+		}
+	}
+
+	now := s.now()
+	// The WHERE clause re-checks the state read above, so two agents locking
+	// the same ticket at once cannot both succeed.
+	res, err := s.db.ExecContext(ctx, database.ConvertPlaceholders(`
+		UPDATE ticket
+		SET ticket_lock_id = ?, user_id = ?, timeout = ?, change_time = ?, change_by = ?
+		WHERE id = ? AND ticket_lock_id = ? AND COALESCE(user_id, 0) = ? AND COALESCE(timeout, 0) = ?`),
+		TicketLockLocked, userID, now.Unix(), now.UTC(), userID,
+		ticketID, r.lockID, r.ownerID, r.lockedAt)
+	if err != nil {
+		return nil, fmt.Errorf("lock ticket: %w", err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil, ErrTicketLockedByOther
+	}
+
+	r.lockID, r.ownerID, r.lockedAt = TicketLockLocked, userID, now.Unix()
+	result.TicketLock = s.state(ticketID, r)
+	return result, nil
+}
+
+// Unlock releases the ticket's lock. Only the holder may release a live
+// lock unless force is set and userID has owner permission on the queue.
+// It returns the agent that held the lock.
+func (s *TicketLockService) Unlock(ctx context.Context, ticketID, userID int, force bool) (int, error) {
+	r, err := s.load(ctx, ticketID)
+	if err != nil {
+		return 0, err
+	}
+	if r.lockID == TicketLockUnlocked {
+		return 0, ErrTicketNotLocked
+	}
+	if s.held(r) && r.ownerID != userID {
+		if !force {
+			return 0, ErrTicketLockedByOther
+		}
+		ok, err := s.canOverride(ctx, userID, r.queueID)
+		if err != nil {
+			return 0, fmt.Errorf("check takeover permission: %w", err)
+		}
+		if !ok {
+			return 0, ErrLockTakeoverDenied
+		}
+	}
+
+	now := s.now()
+	if _, err := s.db.ExecContext(ctx, database.ConvertPlaceholders(`
+		UPDATE ticket SET ticket_lock_id = ?, timeout = ?, change_time = ?, change_by = ?
+		WHERE id = ?`), TicketLockUnlocked, now.Unix(), now.UTC(), userID, ticketID); err != nil {
+		return 0, fmt.Errorf("unlock ticket: %w", err)
+	}
+	return r.ownerID, nil
+}
+
+// CheckReply returns ErrTicketLockedByOther when another agent holds a live
+// lock on the ticket. Unknown tickets pass; access middleware handles them.
+func (s *TicketLockService) CheckReply(ctx context.Context, ticketID, userID int) error {
+	r, err := s.load(ctx, ticketID)
+	if errors.Is(err, ErrLockTicketNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if s.held(r) && r.ownerID != userID {
+		return ErrTicketLockedByOther
+	}
+	return nil
+}
+
+// ExpiredLock is a lock reset by UnlockExpired.
+type ExpiredLock struct {
+	TicketID int
+	QueueID  int
+	OwnerID  int
+}
+
+// UnlockExpired resets locks whose TTL has passed and returns them.
+func (s *TicketLockService) UnlockExpired(ctx context.Context) ([]ExpiredLock, error) {
+	rows, err := s.db.QueryContext(ctx, database.ConvertPlaceholders(`
+		SELECT t.id, t.queue_id, COALESCE(t.user_id, 0), COALESCE(t.timeout, 0), COALESCE(q.unlock_timeout, 0)
+		FROM ticket t
+		JOIN queue q ON q.id = t.queue_id
+		WHERE t.ticket_lock_id <> ?`), TicketLockUnlocked)
+	if err != nil {
+		return nil, fmt.Errorf("list locked tickets: %w", err)
+	}
+	type candidate struct {
+		ExpiredLock
+		lockedAt int64
+	}
+	var expired []candidate
+	for rows.Next() {
+		var id int
+		r := lockRow{lockID: TicketLockLocked}
+		if err := rows.Scan(&id, &r.queueID, &r.ownerID, &r.lockedAt, &r.queueTTL); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("scan locked ticket: %w", err)
+		}
+		if !s.held(&r) {
+			expired = append(expired, candidate{ExpiredLock{TicketID: id, QueueID: r.queueID, OwnerID: r.ownerID}, r.lockedAt})
+		}
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.now()
+	var unlocked []ExpiredLock
+	for _, l := range expired {
+		// Skip tickets that were locked or refreshed since the scan.
+		res, err := s.db.ExecContext(ctx, database.ConvertPlaceholders(`
+			UPDATE ticket SET ticket_lock_id = ?, change_time = ?, change_by = 1
+			WHERE id = ? AND ticket_lock_id <> ? AND COALESCE(timeout, 0) = ?`),
+			TicketLockUnlocked, now.UTC(), l.TicketID, TicketLockUnlocked, l.lockedAt)
+		if err != nil {
+			return unlocked, fmt.Errorf("unlock ticket %d: %w", l.TicketID, err)
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			unlocked = append(unlocked, l.ExpiredLock)
+		}
+	}
+	return unlocked, nil
+}
@@ -0,0 +1,159 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/testutil"
+)
+
+func newTicketLockTestService(t *testing.T) (*TicketLockService, *sql.DB, *time.Time) {
+	t.Helper()
+	db := testutil.MigratedDB(t)
+	_, err := db.Exec(`UPDATE queue SET unlock_timeout = CASE WHEN id = 2 THEN 5 ELSE 0 END`)
+	require.NoError(t, err)
+	for _, ticket := range []struct{ id, queueID int }{{1, 1}, {2, 2}} {
+		_, err := db.Exec(`INSERT INTO ticket (id, tn, title, queue_id, ticket_lock_id, user_id, responsible_user_id,
+			ticket_priority_id, ticket_state_id, timeout, until_time, escalation_time, escalation_update_time,
+			escalation_response_time, escalation_solution_time, archive_flag, create_time, create_by, change_time, change_by)
+			VALUES (?, ?, 'Printer', ?, 1, 1, 1, 3, 1, 0, 0, 0, 0, 0, 0, 0, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+			ticket.id, 1000+ticket.id, ticket.queueID)
+		require.NoError(t, err)
+	}
+
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	svc := NewTicketLockService(db, 0)
+	svc.now = func() time.Time { return now }
+	svc.canOverride = func(_ context.Context, userID, _ int) (bool, error) {
+		return userID == 99, nil
+	}
+	return svc, db, &now
+}
+
+func TestTicketLockService_LockAndRefresh(t *testing.T) {
+	svc, db, now := newTicketLockTestService(t)
+	ctx := context.Background()
+
+	res, err := svc.Lock(ctx, 1, 5, false)
+	require.NoError(t, err)
+	assert.True(t, res.Locked)
+	assert.Equal(t, 5, res.OwnerID)
+	assert.False(t, res.Refreshed)
+	require.NotNil(t, res.ExpiresAt)
+	assert.Equal(t, now.Add(DefaultTicketLockTTL), *res.ExpiresAt)
+
+	var lockID, owner int
+	require.NoError(t, db.QueryRow(`SELECT ticket_lock_id, user_id FROM ticket WHERE id = 1`).Scan(&lockID, &owner))
+	assert.Equal(t, TicketLockLocked, lockID)
+	assert.Equal(t, 5, owner)
+
+	*now = now.Add(10 * time.Minute)
+	res, err = svc.Lock(ctx, 1, 5, false)
+	require.NoError(t, err)
+	assert.True(t, res.Refreshed)
+	assert.Equal(t, now.Add(DefaultTicketLockTTL), *res.ExpiresAt)
+}
+
+func TestTicketLockService_LockedByOther(t *testing.T) {
+	svc, _, _ := newTicketLockTestService(t)
+	ctx := context.Background()
+
+	_, err := svc.Lock(ctx, 1, 5, false)
+	require.NoError(t, err)
+
+	_, err = svc.Lock(ctx, 1, 6, false)
+	assert.ErrorIs(t, err, ErrTicketLockedByOther)
+	assert.ErrorIs(t, svc.CheckReply(ctx, 1, 6), ErrTicketLockedByOther)
+	assert.NoError(t, svc.CheckReply(ctx, 1, 5))
+	assert.NoError(t, svc.CheckReply(ctx, 404, 6))
+
+	_, err = svc.Unlock(ctx, 1, 6, false)
+	assert.ErrorIs(t, err, ErrTicketLockedByOther)
+}
+
+func TestTicketLockService_Takeover(t *testing.T) {
+	svc, _, _ := newTicketLockTestService(t)
+	ctx := context.Background()
+
+	_, err := svc.Lock(ctx, 1, 5, false)
+	require.NoError(t, err)
+
+	_, err = svc.Lock(ctx, 1, 6, true)
+	assert.ErrorIs(t, err, ErrLockTakeoverDenied)
+
+	res, err := svc.Lock(ctx, 1, 99, true)
+	require.NoError(t, err)
+	assert.Equal(t, 99, res.OwnerID)
+	assert.Equal(t, 5, res.PreviousOwnerID)
+
+	_, err = svc.Unlock(ctx, 1, 6, true)
+	assert.ErrorIs(t, err, ErrLockTakeoverDenied)
+}
+
+func TestTicketLockService_ForceUnlock(t *testing.T) {
+	svc, _, _ := newTicketLockTestService(t)
+	ctx := context.Background()
+
+	_, err := svc.Lock(ctx, 1, 5, false)
+	require.NoError(t, err)
+
+	holder, err := svc.Unlock(ctx, 1, 99, true)
+	require.NoError(t, err)
+	assert.Equal(t, 5, holder)
+
+	lock, err := svc.Get(ctx, 1)
+	require.NoError(t, err)
+	assert.False(t, lock.Locked)
+
+	_, err = svc.Unlock(ctx, 1, 5, false)
+	assert.ErrorIs(t, err, ErrTicketNotLocked)
+}
+
+func TestTicketLockService_Expiry(t *testing.T) {
+	svc, db, now := newTicketLockTestService(t)
+	ctx := context.Background()
+
+	_, err := svc.Lock(ctx, 1, 5, false)
+	require.NoError(t, err)
+	_, err = svc.Lock(ctx, 2, 5, false)
+	require.NoError(t, err)
+
+	// Queue 2 has a five minute unlock timeout.
+	*now = now.Add(6 * time.Minute)
+	lock, err := svc.Get(ctx, 2)
+	require.NoError(t, err)
+	assert.False(t, lock.Locked)
+	lock, err = svc.Get(ctx, 1)
+	require.NoError(t, err)
+	assert.True(t, lock.Locked)
+
+	// An expired lock no longer blocks other agents.
+	assert.NoError(t, svc.CheckReply(ctx, 2, 6))
+
+	expired, err := svc.UnlockExpired(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []ExpiredLock{{TicketID: 2, QueueID: 2, OwnerID: 5}}, expired)
+
+	var lockID int
+	require.NoError(t, db.QueryRow(`SELECT ticket_lock_id FROM ticket WHERE id = 2`).Scan(&lockID))
+	assert.Equal(t, TicketLockUnlocked, lockID)
+
+	res, err := svc.Lock(ctx, 2, 6, false)
+	require.NoError(t, err)
+	assert.Equal(t, 0, res.PreviousOwnerID)
+	assert.Equal(t, 6, res.OwnerID)
+}
+
+func TestTicketLockService_NotFound(t *testing.T) {
+	svc, _, _ := newTicketLockTestService(t)
+
+	_, err := svc.Get(context.Background(), 404)
+	assert.ErrorIs(t, err, ErrLockTicketNotFound)
+	_, err = svc.Lock(context.Background(), 404, 5, false)
+	assert.ErrorIs(t, err, ErrLockTicketNotFound)
+}
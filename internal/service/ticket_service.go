@@ -64,6 +64,7 @@ type CreateTicketInput struct {
 	ArticleCommunicationChannelID int
 	PendingUntil                  int // unix seconds when pending should elapse; 0 = none
 	TypeID                        int // optional ticket type to set on create (0 = none)
+	SLAID                         int // optional SLA; defaults from the ticket type's workflow
 	CustomerID                    string
 	CustomerUserID                string
 }
@@ -75,6 +76,17 @@ func (s *ticketService) Create(ctx context.Context, in CreateTicketInput) (*mode
 	if len(in.Title) > 255 {
 		return nil, errors.New("title too long")
 	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if in.TypeID > 0 && (in.QueueID <= 0 || in.PriorityID == 0 || in.SLAID == 0) {
+		if db := s.repo.GetDB(); db != nil {
+			// Defaults are a convenience; the explicit checks below still apply.
+			if err := NewTicketTypeService(db).ApplyDefaults(ctx, in.TypeID, &in.QueueID, &in.PriorityID, &in.SLAID); err != nil {
+				log.Printf("ticket type %d defaults failed: %v", in.TypeID, err)
+			}
+		}
+	}
 	if in.QueueID <= 0 {
 		return nil, errors.New("invalid queue")
 	}
@@ -129,6 +141,10 @@ func (s *ticketService) Create(ctx context.Context, in CreateTicketInput) (*mode
 		tid := in.TypeID
 		ticket.TypeID = &tid
 	}
+	if in.SLAID > 0 {
+		sid := in.SLAID
+		ticket.SLAID = &sid
+	}
 	if (stateTypeID == 4 || stateTypeID == 5) && in.PendingUntil > 0 {
 		ticket.UntilTime = in.PendingUntil
 	} else if (stateTypeID == 4 || stateTypeID == 5) && in.PendingUntil <= 0 {
@@ -138,9 +154,6 @@ func (s *ticketService) Create(ctx context.Context, in CreateTicketInput) (*mode
 		return nil, err
 	}
 
-	if ctx == nil {
-		ctx = context.Background()
-	}
 	if ticket.ChangeTime.IsZero() {
 		ticket.ChangeTime = time.Now()
 	}
@@ -2,13 +2,22 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/goatkit/goatflow/internal/models"
 	"github.com/goatkit/goatflow/internal/repository"
 )
 
+// Ticket template errors.
+var (
+	ErrTicketTemplateNotFound   = repository.ErrTicketTemplateNotFound
+	ErrTicketTemplateInvalid    = errors.New("invalid ticket template")
+	ErrTicketTemplateNameExists = errors.New("a ticket template with this name already exists")
+)
+
 // TicketTemplateService handles business logic for ticket templates.
 type TicketTemplateService struct {
 	repo          repository.TicketTemplateRepository
@@ -29,38 +38,78 @@ func (s *TicketTemplateService) CreateTemplate(ctx context.Context, template *mo
 	if err := s.validateTemplate(template); err != nil {
 		return err
 	}
+	if err := s.checkName(ctx, template); err != nil {
+		return err
+	}
 
 	// Extract variables from subject and body
 	template.Variables = s.extractVariables(template.Subject + " " + template.Body)
 
+	now := time.Now()
+	template.CreatedAt, template.UpdatedAt = now, now
+	if template.UpdatedBy == 0 {
+		template.UpdatedBy = template.CreatedBy
+	}
 	return s.repo.CreateTemplate(ctx, template)
 }
 
 // GetTemplate retrieves a template by ID.
 func (s *TicketTemplateService) GetTemplate(ctx context.Context, id uint) (*models.TicketTemplate, error) {
-	return s.repo.GetTemplateByID(ctx, id)
+	template, err := s.repo.GetTemplateByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	template.Variables = s.extractVariables(template.Subject + " " + template.Body)
+	return template, nil
+}
+
+// GetAllTemplates retrieves all templates, including inactive ones.
+func (s *TicketTemplateService) GetAllTemplates(ctx context.Context) ([]models.TicketTemplate, error) {
+	return s.withVariables(s.repo.GetAllTemplates(ctx))
 }
 
 // GetActiveTemplates retrieves all active templates.
 func (s *TicketTemplateService) GetActiveTemplates(ctx context.Context) ([]models.TicketTemplate, error) {
-	return s.repo.GetActiveTemplates(ctx)
+	return s.withVariables(s.repo.GetActiveTemplates(ctx))
 }
 
 // GetTemplatesByCategory retrieves templates by category.
 func (s *TicketTemplateService) GetTemplatesByCategory(ctx context.Context, category string) ([]models.TicketTemplate, error) {
-	return s.repo.GetTemplatesByCategory(ctx, category)
+	return s.withVariables(s.repo.GetTemplatesByCategory(ctx, category))
+}
+
+// GetTemplatesForUser retrieves the active templates an agent may use:
+// templates without groups and those of the agent's groups.
+func (s *TicketTemplateService) GetTemplatesForUser(ctx context.Context, userID int) ([]models.TicketTemplate, error) {
+	return s.withVariables(s.repo.GetTemplatesForUser(ctx, userID))
+}
+
+// RecordUsage counts a ticket created from a template.
+func (s *TicketTemplateService) RecordUsage(ctx context.Context, id uint) error {
+	return s.repo.IncrementUsageCount(ctx, id)
 }
 
 // UpdateTemplate updates an existing template.
 func (s *TicketTemplateService) UpdateTemplate(ctx context.Context, template *models.TicketTemplate) error {
+	existing, err := s.repo.GetTemplateByID(ctx, template.ID)
+	if err != nil {
+		return err
+	}
+
 	// Validate template
 	if err := s.validateTemplate(template); err != nil {
 		return err
 	}
+	if err := s.checkName(ctx, template); err != nil {
+		return err
+	}
 
 	// Re-extract variables
 	template.Variables = s.extractVariables(template.Subject + " " + template.Body)
 
+	template.CreatedAt, template.CreatedBy = existing.CreatedAt, existing.CreatedBy
+	template.UsageCount = existing.UsageCount
+	template.UpdatedAt = time.Now()
 	return s.repo.UpdateTemplate(ctx, template)
 }
 
@@ -71,7 +120,7 @@ func (s *TicketTemplateService) DeleteTemplate(ctx context.Context, id uint) err
 
 // SearchTemplates searches for templates.
 func (s *TicketTemplateService) SearchTemplates(ctx context.Context, query string) ([]models.TicketTemplate, error) {
-	return s.repo.SearchTemplates(ctx, query)
+	return s.withVariables(s.repo.SearchTemplates(ctx, query))
 }
 
 // GetCategories retrieves all template categories.
@@ -123,21 +172,60 @@ func (s *TicketTemplateService) ApplyTemplate(ctx context.Context, application *
 
 // validateTemplate validates a template before saving.
 func (s *TicketTemplateService) validateTemplate(template *models.TicketTemplate) error {
+	template.Name = strings.TrimSpace(template.Name)
 	if template.Name == "" {
-		return fmt.Errorf("template name is required")
+		return fmt.Errorf("%w: template name is required", ErrTicketTemplateInvalid)
 	}
 	if template.Subject == "" {
-		return fmt.Errorf("template subject is required")
+		return fmt.Errorf("%w: template subject is required", ErrTicketTemplateInvalid)
 	}
 	if template.Body == "" {
-		return fmt.Errorf("template body is required")
+		return fmt.Errorf("%w: template body is required", ErrTicketTemplateInvalid)
+	}
+	if len(template.Name) > 200 {
+		return fmt.Errorf("%w: template name too long (max 200 characters)", ErrTicketTemplateInvalid)
+	}
+	if len(template.Subject) > 255 {
+		return fmt.Errorf("%w: template subject too long (max 255 characters)", ErrTicketTemplateInvalid)
 	}
-	if len(template.Name) > 255 {
-		return fmt.Errorf("template name too long (max 255 characters)")
+	switch template.ContentType {
+	case "":
+		template.ContentType = "text/plain"
+	case "text/plain", "text/html":
+	default:
+		return fmt.Errorf("%w: content type must be text/plain or text/html", ErrTicketTemplateInvalid)
+	}
+	for _, id := range append([]int{template.QueueID, template.PriorityID, template.TypeID, template.StateID, template.ServiceID}, template.GroupIDs...) {
+		if id < 0 {
+			return fmt.Errorf("%w: IDs must not be negative", ErrTicketTemplateInvalid)
+		}
 	}
 	return nil
 }
 
+// checkName rejects a template whose name another template already uses.
+func (s *TicketTemplateService) checkName(ctx context.Context, template *models.TicketTemplate) error {
+	exists, err := s.repo.TemplateNameExists(ctx, template.Name, template.ID)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return ErrTicketTemplateNameExists
+	}
+	return nil
+}
+
+// withVariables fills in the variables of listed templates.
+func (s *TicketTemplateService) withVariables(templates []models.TicketTemplate, err error) ([]models.TicketTemplate, error) {
+	if err != nil {
+		return nil, err
+	}
+	for i := range templates {
+		templates[i].Variables = s.extractVariables(templates[i].Subject + " " + templates[i].Body)
+	}
+	return templates, nil
+}
+
 // extractVariables extracts variable placeholders from text.
 func (s *TicketTemplateService) extractVariables(text string) []models.TemplateVariable {
 	variableMap := make(map[string]bool)
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/repository"
+)
+
+func TestTicketTemplateService(t *testing.T) {
+	ctx := context.Background()
+	svc := NewTicketTemplateService(repository.NewMemoryTicketTemplateRepository(), nil)
+
+	template := &models.TicketTemplate{
+		Name: " Password reset ", Subject: "Reset for {{customer}}", Body: "Hello {{customer}}",
+		QueueID: 2, Active: true, CreatedBy: 1,
+	}
+	require.NoError(t, svc.CreateTemplate(ctx, template))
+	assert.Equal(t, "Password reset", template.Name)
+	assert.Equal(t, "text/plain", template.ContentType)
+	assert.Equal(t, 1, template.UpdatedBy)
+
+	got, err := svc.GetTemplate(ctx, template.ID)
+	require.NoError(t, err)
+	require.Len(t, got.Variables, 1)
+	assert.Equal(t, "{{customer}}", got.Variables[0].Name)
+
+	duplicate := &models.TicketTemplate{Name: "Password reset", Subject: "x", Body: "y"}
+	assert.ErrorIs(t, svc.CreateTemplate(ctx, duplicate), ErrTicketTemplateNameExists)
+
+	for name, invalid := range map[string]*models.TicketTemplate{
+		"no name":      {Subject: "x", Body: "y"},
+		"no subject":   {Name: "a", Body: "y"},
+		"no body":      {Name: "a", Subject: "x"},
+		"content type": {Name: "a", Subject: "x", Body: "y", ContentType: "application/pdf"},
+		"negative id":  {Name: "a", Subject: "x", Body: "y", GroupIDs: []int{-1}},
+	} {
+		assert.ErrorIs(t, svc.CreateTemplate(ctx, invalid), ErrTicketTemplateInvalid, name)
+	}
+
+	require.NoError(t, svc.RecordUsage(ctx, template.ID))
+	update := &models.TicketTemplate{
+		ID: template.ID, Name: "Password reset", Subject: "Reset", Body: "Hello",
+		ContentType: "text/html", Active: true, UpdatedBy: 2,
+	}
+	require.NoError(t, svc.UpdateTemplate(ctx, update))
+	got, err = svc.GetTemplate(ctx, template.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, got.UsageCount)
+	assert.Equal(t, 1, got.CreatedBy)
+	assert.Equal(t, 2, got.UpdatedBy)
+	assert.Empty(t, got.Variables)
+
+	update.ID = 999
+	assert.ErrorIs(t, svc.UpdateTemplate(ctx, update), ErrTicketTemplateNotFound)
+
+	visible, err := svc.GetTemplatesForUser(ctx, 5)
+	require.NoError(t, err)
+	assert.Len(t, visible, 1)
+}
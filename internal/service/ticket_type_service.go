@@ -0,0 +1,245 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/repository"
+)
+
+// Errors returned by TicketTypeService.
+var (
+	ErrTicketTypeNotFound             = errors.New("ticket type not found")
+	ErrTicketTypeNameRequired         = errors.New("ticket type name is required")
+	ErrTicketTypeNameTooLong          = errors.New("ticket type name must be at most 200 characters")
+	ErrTicketTypeNameExists           = errors.New("a ticket type with this name already exists")
+	ErrTicketTypeInUse                = errors.New("ticket type is used by tickets")
+	ErrTicketTypeInvalidDefault       = errors.New("default queue, priority and SLA must be valid")
+	ErrTicketTypeInvalidField         = errors.New("dynamic fields must be valid ticket fields")
+	ErrTicketTypeInvalidState         = errors.New("transitions must use valid states")
+	ErrTicketTypeTransitionNotAllowed = errors.New("the ticket type does not allow this state change")
+	ErrTicketTypeTicketNotFound       = errors.New("ticket not found")
+)
+
+// TicketTypeService manages ticket types and the per-type workflows that
+// let one system handle incidents, requests and changes differently.
+type TicketTypeService struct {
+	repo *repository.TicketTypeRepository
+}
+
+// NewTicketTypeService creates a ticket type service.
+func NewTicketTypeService(db *sql.DB) *TicketTypeService {
+	return &TicketTypeService{repo: repository.NewTicketTypeRepository(db)}
+}
+
+// List returns ticket types with their ticket counts.
+func (s *TicketTypeService) List(ctx context.Context, includeInvalid bool) ([]models.TicketType, error) {
+	return s.repo.List(ctx, includeInvalid)
+}
+
+// Get returns a ticket type.
+func (s *TicketTypeService) Get(ctx context.Context, id int) (*models.TicketType, error) {
+	tt, err := s.repo.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if tt == nil {
+		return nil, ErrTicketTypeNotFound
+	}
+	return tt, nil
+}
+
+// Create validates and stores a new ticket type, setting its ID.
+func (s *TicketTypeService) Create(ctx context.Context, tt *models.TicketType) error {
+	if err := s.validate(ctx, tt); err != nil {
+		return err
+	}
+	id, err := s.repo.Create(ctx, tt)
+	if err != nil {
+		return err
+	}
+	tt.ID = id
+	return nil
+}
+
+// Update validates and stores changes to a ticket type.
+func (s *TicketTypeService) Update(ctx context.Context, tt *models.TicketType, userID int) error {
+	if err := s.validate(ctx, tt); err != nil {
+		return err
+	}
+	if err := s.repo.Update(ctx, tt, userID); errors.Is(err, sql.ErrNoRows) {
+		return ErrTicketTypeNotFound
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+// Delete invalidates a ticket type. Types still used by tickets are kept so
+// that reports and searches over those tickets stay meaningful.
+func (s *TicketTypeService) Delete(ctx context.Context, id int, userID int) error {
+	tt, err := s.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+	if tt.TicketCount > 0 {
+		return ErrTicketTypeInUse
+	}
+	if err := s.repo.Invalidate(ctx, id, userID); errors.Is(err, sql.ErrNoRows) {
+		return ErrTicketTypeNotFound
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+func (s *TicketTypeService) validate(ctx context.Context, tt *models.TicketType) error {
+	tt.Name = strings.TrimSpace(tt.Name)
+	if tt.Name == "" {
+		return ErrTicketTypeNameRequired
+	}
+	if len(tt.Name) > 200 {
+		return ErrTicketTypeNameTooLong
+	}
+	if tt.ValidID == 0 {
+		tt.ValidID = 1
+	}
+	taken, err := s.repo.NameExists(ctx, tt.Name, tt.ID)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return ErrTicketTypeNameExists
+	}
+	return nil
+}
+
+// GetWorkflow returns the workflow of a ticket type.
+func (s *TicketTypeService) GetWorkflow(ctx context.Context, typeID int) (*models.TicketTypeWorkflow, error) {
+	if _, err := s.Get(ctx, typeID); err != nil {
+		return nil, err
+	}
+	return s.repo.GetWorkflow(ctx, typeID)
+}
+
+// SaveWorkflow validates and replaces the workflow of a ticket type. Zero
+// default IDs clear the default; duplicate fields and transitions are
+// dropped.
+func (s *TicketTypeService) SaveWorkflow(ctx context.Context, wf *models.TicketTypeWorkflow, userID int) error {
+	if _, err := s.Get(ctx, wf.TypeID); err != nil {
+		return err
+	}
+
+	defaults := []struct {
+		table string
+		id    **int
+	}{
+		{"queue", &wf.DefaultQueueID},
+		{"ticket_priority", &wf.DefaultPriorityID},
+		{"sla", &wf.DefaultSLAID},
+	}
+	for _, d := range defaults {
+		if *d.id == nil {
+			continue
+		}
+		if **d.id <= 0 {
+			*d.id = nil
+			continue
+		}
+		if ok, err := s.repo.ValidRowExists(ctx, d.table, **d.id); err != nil {
+			return err
+		} else if !ok {
+			return ErrTicketTypeInvalidDefault
+		}
+	}
+
+	fields := make([]models.TicketTypeField, 0, len(wf.DynamicFields))
+	seenFields := map[int]bool{}
+	for _, f := range wf.DynamicFields {
+		if seenFields[f.FieldID] {
+			continue
+		}
+		seenFields[f.FieldID] = true
+		if ok, err := s.repo.TicketDynamicFieldExists(ctx, f.FieldID); err != nil {
+			return err
+		} else if !ok {
+			return ErrTicketTypeInvalidField
+		}
+		fields = append(fields, f)
+	}
+	wf.DynamicFields = fields
+
+	transitions := make([]models.TicketTypeTransition, 0, len(wf.Transitions))
+	seenTransitions := map[models.TicketTypeTransition]bool{}
+	validStates := map[int]bool{}
+	for _, t := range wf.Transitions {
+		if t.FromStateID == t.ToStateID || seenTransitions[t] {
+			continue
+		}
+		seenTransitions[t] = true
+		for _, stateID := range []int{t.FromStateID, t.ToStateID} {
+			if validStates[stateID] {
+				continue
+			}
+			ok, err := s.repo.ValidRowExists(ctx, "ticket_state", stateID)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return ErrTicketTypeInvalidState
+			}
+			validStates[stateID] = true
+		}
+		transitions = append(transitions, t)
+	}
+	wf.Transitions = transitions
+
+	return s.repo.SaveWorkflow(ctx, wf, userID)
+}
+
+// ApplyDefaults fills the queue, priority and SLA left unset (zero) from the
+// workflow of typeID. Types without a workflow leave them unchanged.
+func (s *TicketTypeService) ApplyDefaults(ctx context.Context, typeID int, queueID, priorityID, slaID *int) error {
+	if typeID <= 0 {
+		return nil
+	}
+	wf, err := s.repo.GetWorkflow(ctx, typeID)
+	if err != nil {
+		return err
+	}
+	fill := func(dst *int, def *int) {
+		if dst != nil && *dst <= 0 && def != nil {
+			*dst = *def
+		}
+	}
+	fill(queueID, wf.DefaultQueueID)
+	fill(priorityID, wf.DefaultPriorityID)
+	fill(slaID, wf.DefaultSLAID)
+	return nil
+}
+
+// CheckTransition returns ErrTicketTypeTransitionNotAllowed when the
+// ticket's type does not allow moving it to stateID.
+func (s *TicketTypeService) CheckTransition(ctx context.Context, ticketID, stateID int) error {
+	typeID, fromStateID, ok, err := s.repo.TicketTypeAndState(ctx, ticketID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrTicketTypeTicketNotFound
+	}
+	if typeID == 0 || fromStateID == stateID {
+		return nil
+	}
+	wf, err := s.repo.GetWorkflow(ctx, typeID)
+	if err != nil {
+		return err
+	}
+	if !wf.AllowsTransition(fromStateID, stateID) {
+		return ErrTicketTypeTransitionNotAllowed
+	}
+	return nil
+}
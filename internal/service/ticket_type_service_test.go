@@ -0,0 +1,166 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/models"
+	"github.com/goatkit/goatflow/internal/testutil"
+)
+
+func newTicketTypeTestService(t *testing.T) (*TicketTypeService, *sql.DB) {
+	t.Helper()
+	db := testutil.MigratedDB(t)
+	for _, stmt := range []string{
+		`INSERT INTO users (id, login, pw, first_name, last_name, valid_id, create_time, create_by, change_time, change_by)
+			VALUES (1, 'root@localhost', 'x', 'Admin', 'OTRS', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1),
+			       (2, 'agent2', 'x', 'Agent', 'Two', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1),
+			       (3, 'agent3', 'x', 'Agent', 'Three', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+		`UPDATE queue SET valid_id = 2 WHERE id = 3`,
+		`INSERT INTO sla (id, name, first_response_time, update_time, solution_time, valid_id,
+			create_time, create_by, change_time, change_by)
+			VALUES (2, 'Gold', 0, 0, 0, 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+		`INSERT INTO dynamic_field (id, name, label, field_order, field_type, object_type, valid_id,
+			create_time, create_by, change_time, change_by)
+			VALUES (7, 'ChangeWindow', 'Change window', 1, 'Text', 'Ticket', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1),
+			       (8, 'ArticleTag', 'Article tag', 2, 'Text', 'Article', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+	} {
+		_, err := db.Exec(stmt)
+		require.NoError(t, err, stmt)
+	}
+	return NewTicketTypeService(db), db
+}
+
+// insertTicketTypeTestTicket adds a new ticket of the given type; a nil
+// type leaves it untyped.
+func insertTicketTypeTestTicket(t *testing.T, db *sql.DB, id int, typeID *int) {
+	t.Helper()
+	_, err := db.Exec(`INSERT INTO ticket (id, tn, title, queue_id, ticket_lock_id, type_id, user_id, responsible_user_id,
+		ticket_priority_id, ticket_state_id, timeout, until_time, escalation_time, escalation_update_time,
+		escalation_response_time, escalation_solution_time, archive_flag, create_time, create_by, change_time, change_by)
+		VALUES (?, ?, 'Printer', 1, 1, ?, 1, 1, 3, 1, 0, 0, 0, 0, 0, 0, 0, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+		id, fmt.Sprint(1000+id), typeID)
+	require.NoError(t, err)
+}
+
+func TestTicketTypeService_CRUD(t *testing.T) {
+	svc, db := newTicketTypeTestService(t)
+	ctx := context.Background()
+
+	tt := &models.TicketType{Name: "  Change ", CreateBy: 1}
+	require.NoError(t, svc.Create(ctx, tt))
+	assert.Equal(t, "Change", tt.Name)
+	assert.Equal(t, 1, tt.ValidID)
+
+	assert.ErrorIs(t, svc.Create(ctx, &models.TicketType{Name: "change"}), ErrTicketTypeNameExists)
+	assert.ErrorIs(t, svc.Create(ctx, &models.TicketType{Name: " "}), ErrTicketTypeNameRequired)
+
+	tt.Name = "Normal Change"
+	require.NoError(t, svc.Update(ctx, tt, 2))
+	got, err := svc.Get(ctx, tt.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Normal Change", got.Name)
+	assert.Equal(t, 2, got.ChangeBy)
+
+	assert.ErrorIs(t, svc.Update(ctx, &models.TicketType{ID: 99, Name: "Missing"}, 1), ErrTicketTypeNotFound)
+
+	insertTicketTypeTestTicket(t, db, 1, &tt.ID)
+	assert.ErrorIs(t, svc.Delete(ctx, tt.ID, 1), ErrTicketTypeInUse)
+
+	_, err = db.Exec(`DELETE FROM ticket`)
+	require.NoError(t, err)
+	require.NoError(t, svc.Delete(ctx, tt.ID, 1))
+	types, err := svc.List(ctx, false)
+	require.NoError(t, err)
+	assert.Len(t, types, 5, "only the seeded types remain valid")
+	types, err = svc.List(ctx, true)
+	require.NoError(t, err)
+	assert.Len(t, types, 6)
+}
+
+func TestTicketTypeService_Workflow(t *testing.T) {
+	svc, _ := newTicketTypeTestService(t)
+	ctx := context.Background()
+	tt := &models.TicketType{Name: "Change", CreateBy: 1}
+	require.NoError(t, svc.Create(ctx, tt))
+
+	wf, err := svc.GetWorkflow(ctx, tt.ID)
+	require.NoError(t, err)
+	assert.Nil(t, wf.DefaultQueueID)
+	assert.Empty(t, wf.Transitions)
+
+	err = svc.SaveWorkflow(ctx, &models.TicketTypeWorkflow{TypeID: tt.ID, DefaultQueueID: intRef(3)}, 1)
+	assert.ErrorIs(t, err, ErrTicketTypeInvalidDefault)
+	err = svc.SaveWorkflow(ctx, &models.TicketTypeWorkflow{TypeID: tt.ID,
+		DynamicFields: []models.TicketTypeField{{FieldID: 8}}}, 1)
+	assert.ErrorIs(t, err, ErrTicketTypeInvalidField)
+	err = svc.SaveWorkflow(ctx, &models.TicketTypeWorkflow{TypeID: tt.ID,
+		Transitions: []models.TicketTypeTransition{{FromStateID: 1, ToStateID: 42}}}, 1)
+	assert.ErrorIs(t, err, ErrTicketTypeInvalidState)
+	err = svc.SaveWorkflow(ctx, &models.TicketTypeWorkflow{TypeID: 99}, 1)
+	assert.ErrorIs(t, err, ErrTicketTypeNotFound)
+
+	require.NoError(t, svc.SaveWorkflow(ctx, &models.TicketTypeWorkflow{
+		TypeID:            tt.ID,
+		DefaultQueueID:    intRef(4),
+		DefaultPriorityID: intRef(0),
+		DefaultSLAID:      intRef(2),
+		DynamicFields:     []models.TicketTypeField{{FieldID: 7, Required: true}, {FieldID: 7}},
+		Transitions: []models.TicketTypeTransition{
+			{FromStateID: 1, ToStateID: 2}, {FromStateID: 1, ToStateID: 2}, {FromStateID: 2, ToStateID: 2},
+		},
+	}, 3))
+
+	wf, err = svc.GetWorkflow(ctx, tt.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 4, *wf.DefaultQueueID)
+	assert.Nil(t, wf.DefaultPriorityID)
+	assert.Equal(t, 2, *wf.DefaultSLAID)
+	assert.Equal(t, []models.TicketTypeField{{FieldID: 7, Required: true}}, wf.DynamicFields)
+	assert.Equal(t, []models.TicketTypeTransition{{FromStateID: 1, ToStateID: 2}}, wf.Transitions)
+	assert.Equal(t, 3, wf.ChangeBy)
+
+	queueID, priorityID, slaID := 0, 0, 0
+	require.NoError(t, svc.ApplyDefaults(ctx, tt.ID, &queueID, &priorityID, &slaID))
+	assert.Equal(t, 4, queueID)
+	assert.Equal(t, 0, priorityID)
+	assert.Equal(t, 2, slaID)
+
+	queueID = 1
+	require.NoError(t, svc.ApplyDefaults(ctx, tt.ID, &queueID, nil, nil))
+	assert.Equal(t, 1, queueID, "explicit values win over type defaults")
+}
+
+func TestTicketTypeService_CheckTransition(t *testing.T) {
+	svc, db := newTicketTypeTestService(t)
+	ctx := context.Background()
+	tt := &models.TicketType{Name: "Change", CreateBy: 1}
+	require.NoError(t, svc.Create(ctx, tt))
+	require.NoError(t, svc.SaveWorkflow(ctx, &models.TicketTypeWorkflow{
+		TypeID:      tt.ID,
+		Transitions: []models.TicketTypeTransition{{FromStateID: 1, ToStateID: 2}, {FromStateID: 2, ToStateID: 4}},
+	}, 1))
+	insertTicketTypeTestTicket(t, db, 1, &tt.ID)
+	insertTicketTypeTestTicket(t, db, 2, nil)
+
+	assert.NoError(t, svc.CheckTransition(ctx, 1, 2))
+	assert.NoError(t, svc.CheckTransition(ctx, 1, 1))
+	assert.ErrorIs(t, svc.CheckTransition(ctx, 1, 4), ErrTicketTypeTransitionNotAllowed)
+	assert.NoError(t, svc.CheckTransition(ctx, 2, 4), "untyped tickets are not restricted")
+	assert.ErrorIs(t, svc.CheckTransition(ctx, 3, 4), ErrTicketTypeTicketNotFound)
+}
+
+func TestTicketTypeWorkflow_NextStates(t *testing.T) {
+	wf := &models.TicketTypeWorkflow{Transitions: []models.TicketTypeTransition{
+		{FromStateID: 1, ToStateID: 4}, {FromStateID: 1, ToStateID: 2}, {FromStateID: 4, ToStateID: 2},
+	}}
+	assert.Equal(t, []int{1, 4, 2}, wf.NextStates(1))
+	assert.Equal(t, []int{2}, wf.NextStates(2))
+	assert.Nil(t, (&models.TicketTypeWorkflow{}).NextStates(1))
+	assert.True(t, wf.ShowsField(5))
+}
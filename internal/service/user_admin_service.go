@@ -0,0 +1,243 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/goatkit/goatflow/internal/auth"
+	"github.com/goatkit/goatflow/internal/database"
+)
+
+// Errors returned by UserAdminService.
+var (
+	ErrAgentExists        = errors.New("agent already exists")
+	ErrAgentInvalid       = errors.New("invalid agent")
+	ErrGroupNotFound      = errors.New("group not found")
+	ErrGroupPermission    = errors.New("unknown group permission")
+	ErrAgentPasswordEmpty = errors.New("a password is required")
+)
+
+// AgentPasswordPolicyError is returned when a password does not meet the
+// agent password policy.
+type AgentPasswordPolicyError struct {
+	Code string // sysconfig.PasswordValidationError code
+}
+
+func (e *AgentPasswordPolicyError) Error() string {
+	return "password does not meet the password policy: " + e.Code
+}
+
+// NewAgent describes an agent account to create.
+type NewAgent struct {
+	Login     string   `json:"login"`
+	FirstName string   `json:"first_name"`
+	LastName  string   `json:"last_name"`
+	Title     string   `json:"title,omitempty"`
+	Password  string   `json:"password"`
+	Groups    []string `json:"groups,omitempty"` // group names, joined with rw
+}
+
+// UserAdminService creates agents and manages their passwords, lock state
+// and group memberships by login, for operators without the web UI. Passwords
+// go through the agent password policy like a change in the UI.
+type UserAdminService struct {
+	db     *sql.DB
+	policy *PasswordPolicyService
+	now    func() time.Time
+}
+
+// NewUserAdminService creates a user administration service.
+func NewUserAdminService(db *sql.DB) *UserAdminService {
+	return &UserAdminService{db: db, policy: NewPasswordPolicyService(db), now: time.Now}
+}
+
+// CreateAgent creates a valid agent and adds it to its groups with rw,
+// returning the new user ID. changeBy is the acting user.
+func (s *UserAdminService) CreateAgent(ctx context.Context, a NewAgent, changeBy int) (int, error) {
+	a.Login = strings.TrimSpace(a.Login)
+	if a.Login == "" || strings.TrimSpace(a.FirstName) == "" || strings.TrimSpace(a.LastName) == "" {
+		return 0, fmt.Errorf("%w: login, first name and last name are required", ErrAgentInvalid)
+	}
+	if len(a.Title) > 50 {
+		return 0, fmt.Errorf("%w: title is longer than 50 characters", ErrAgentInvalid)
+	}
+	if _, err := s.policy.account(ctx, PasswordAccountAgent, a.Login); err == nil {
+		return 0, ErrAgentExists
+	} else if !errors.Is(err, ErrPasswordAccountNotFound) {
+		return 0, err
+	}
+	groupIDs := make([]int, 0, len(a.Groups))
+	for _, name := range a.Groups {
+		id, err := s.groupID(ctx, name)
+		if err != nil {
+			return 0, err
+		}
+		groupIDs = append(groupIDs, id)
+	}
+	hash, err := s.checkPassword(ctx, a.Login, a.Password)
+	if err != nil {
+		return 0, err
+	}
+
+	now := s.now()
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = tx.Rollback() }()
+	id, err := database.GetAdapter().InsertWithReturningTx(tx, database.ConvertPlaceholders(`
+		INSERT INTO users (login, pw, title, first_name, last_name, valid_id, create_time, create_by, change_time, change_by)
+		VALUES (?, ?, ?, ?, ?, 1, ?, ?, ?, ?)
+		RETURNING id
+	`), a.Login, hash, a.Title, a.FirstName, a.LastName, now, changeBy, now, changeBy)
+	if err != nil {
+		return 0, fmt.Errorf("create agent: %w", err)
+	}
+	for _, groupID := range groupIDs {
+		if err := setMembership(ctx, tx, int(id), groupID, "rw", changeBy, now); err != nil {
+			return 0, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("create agent: %w", err)
+	}
+	if err := s.policy.RecordChange(ctx, PasswordAccountAgent, a.Login, hash); err != nil {
+		log.Printf("user admin: recording password history for agent %s failed: %v", a.Login, err)
+	}
+	return int(id), nil
+}
+
+// SetPassword sets an agent's password and resets its failed login count.
+// A locked agent stays locked until Unlock.
+func (s *UserAdminService) SetPassword(ctx context.Context, login, password string, changeBy int) error {
+	acct, err := s.policy.account(ctx, PasswordAccountAgent, login)
+	if err != nil {
+		return err
+	}
+	hash, err := s.checkPassword(ctx, acct.login, password)
+	if err != nil {
+		return err
+	}
+	if _, err := s.db.ExecContext(ctx, database.ConvertPlaceholders(`
+		UPDATE users SET pw = ?, change_time = ?, change_by = ? WHERE id = ?
+	`), hash, s.now(), changeBy, acct.id); err != nil {
+		return fmt.Errorf("set password: %w", err)
+	}
+	if err := s.policy.RecordChange(ctx, PasswordAccountAgent, acct.login, hash); err != nil {
+		return fmt.Errorf("record password: %w", err)
+	}
+	return s.policy.RecordLoginSuccess(ctx, PasswordAccountAgent, acct.login)
+}
+
+// Lock sets an agent invalid-temporarily, as the failed login lockout does.
+func (s *UserAdminService) Lock(ctx context.Context, login string, changeBy int) error {
+	acct, err := s.policy.account(ctx, PasswordAccountAgent, login)
+	if err != nil {
+		return err
+	}
+	return s.setValid(ctx, acct.id, validTemporarilyInvalid, changeBy)
+}
+
+// Unlock makes an agent valid again and resets its failed login count.
+func (s *UserAdminService) Unlock(ctx context.Context, login string, changeBy int) error {
+	acct, err := s.policy.account(ctx, PasswordAccountAgent, login)
+	if err != nil {
+		return err
+	}
+	if err := s.setValid(ctx, acct.id, 1, changeBy); err != nil {
+		return err
+	}
+	return s.policy.RecordLoginSuccess(ctx, PasswordAccountAgent, acct.login)
+}
+
+// AddToGroup gives an agent a permission in a group. Adding a membership the
+// agent already has does nothing.
+func (s *UserAdminService) AddToGroup(ctx context.Context, login, group, permission string, changeBy int) error {
+	if permission == "" {
+		permission = "rw"
+	}
+	if !isPermissionType(permission) {
+		return fmt.Errorf("%w: %s", ErrGroupPermission, permission)
+	}
+	acct, err := s.policy.account(ctx, PasswordAccountAgent, login)
+	if err != nil {
+		return err
+	}
+	groupID, err := s.groupID(ctx, group)
+	if err != nil {
+		return err
+	}
+	return setMembership(ctx, s.db, acct.id, groupID, permission, changeBy, s.now())
+}
+
+// checkPassword checks a password against the agent policy and hashes it.
+func (s *UserAdminService) checkPassword(ctx context.Context, login, password string) (string, error) {
+	if password == "" {
+		return "", ErrAgentPasswordEmpty
+	}
+	verr, err := s.policy.CheckPassword(ctx, PasswordAccountAgent, login, password)
+	if err != nil {
+		return "", err
+	}
+	if verr != nil {
+		return "", &AgentPasswordPolicyError{Code: verr.Code}
+	}
+	hash, err := auth.NewPasswordHasher().HashPassword(password)
+	if err != nil {
+		return "", fmt.Errorf("hash password: %w", err)
+	}
+	return hash, nil
+}
+
+func (s *UserAdminService) setValid(ctx context.Context, userID, validID, changeBy int) error {
+	if _, err := s.db.ExecContext(ctx, database.ConvertPlaceholders(`
+		UPDATE users SET valid_id = ?, change_time = ?, change_by = ? WHERE id = ?
+	`), validID, s.now(), changeBy, userID); err != nil {
+		return fmt.Errorf("update agent: %w", err)
+	}
+	return nil
+}
+
+func (s *UserAdminService) groupID(ctx context.Context, name string) (int, error) {
+	var id int
+	err := s.db.QueryRowContext(ctx, database.ConvertPlaceholders(`
+		SELECT id FROM groups WHERE name = ? AND valid_id = 1
+	`), strings.TrimSpace(name)).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, fmt.Errorf("%w: %s", ErrGroupNotFound, name)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("load group: %w", err)
+	}
+	return id, nil
+}
+
+// execer is the part of *sql.DB and *sql.Tx that setMembership uses.
+type execer interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+func setMembership(ctx context.Context, db execer, userID, groupID int, permission string, changeBy int, now time.Time) error {
+	var n int
+	if err := db.QueryRowContext(ctx, database.ConvertPlaceholders(`
+		SELECT COUNT(*) FROM group_user WHERE user_id = ? AND group_id = ? AND permission_key = ?
+	`), userID, groupID, permission).Scan(&n); err != nil {
+		return fmt.Errorf("load group membership: %w", err)
+	}
+	if n > 0 {
+		return nil
+	}
+	if _, err := db.ExecContext(ctx, database.ConvertPlaceholders(`
+		INSERT INTO group_user (user_id, group_id, permission_key, create_time, create_by, change_time, change_by)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`), userID, groupID, permission, now, changeBy, now, changeBy); err != nil {
+		return fmt.Errorf("add group membership: %w", err)
+	}
+	return nil
+}
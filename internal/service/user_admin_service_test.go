@@ -0,0 +1,75 @@
+package service
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/auth"
+	"github.com/goatkit/goatflow/internal/sysconfig"
+	"github.com/goatkit/goatflow/internal/testutil"
+)
+
+func TestUserAdminService(t *testing.T) {
+	db := testutil.UseMigratedDB(t)
+	require.NoError(t, sysconfig.SaveAgentPasswordPolicy(db, sysconfig.PasswordPolicy{
+		PasswordMinSize: 10, PasswordMaxLoginFailed: 3, PasswordHistory: 2,
+	}, 1))
+	svc := NewUserAdminService(db)
+	svc.policy.WithBreachChecker(&fakeBreachChecker{})
+	ctx := context.Background()
+
+	_, err := svc.CreateAgent(ctx, NewAgent{Login: "ann", FirstName: "Ann", Password: "long enough"}, 1)
+	assert.ErrorIs(t, err, ErrAgentInvalid, "last name is required")
+	_, err = svc.CreateAgent(ctx, NewAgent{Login: "ann", FirstName: "Ann", LastName: "Lee", Password: "short"}, 1)
+	var policyErr *AgentPasswordPolicyError
+	require.ErrorAs(t, err, &policyErr)
+	assert.Equal(t, "min_size", policyErr.Code)
+	_, err = svc.CreateAgent(ctx, NewAgent{Login: "ann", FirstName: "Ann", LastName: "Lee", Password: "long enough", Groups: []string{"nope"}}, 1)
+	assert.ErrorIs(t, err, ErrGroupNotFound)
+
+	id, err := svc.CreateAgent(ctx, NewAgent{Login: "ann", FirstName: "Ann", LastName: "Lee", Password: "long enough", Groups: []string{"admin"}}, 1)
+	require.NoError(t, err)
+	assert.Positive(t, id)
+	var pw string
+	var validID int
+	require.NoError(t, db.QueryRow(`SELECT pw, valid_id FROM users WHERE id = ?`, id).Scan(&pw, &validID))
+	assert.True(t, auth.NewPasswordHasher().VerifyPassword("long enough", pw))
+	assert.Equal(t, 1, validID)
+	var memberships int
+	require.NoError(t, db.QueryRow(`SELECT COUNT(*) FROM group_user gu JOIN groups g ON g.id = gu.group_id
+		WHERE gu.user_id = ? AND g.name = 'admin' AND gu.permission_key = 'rw'`, id).Scan(&memberships))
+	assert.Equal(t, 1, memberships)
+	_, err = svc.CreateAgent(ctx, NewAgent{Login: "ann", FirstName: "Ann", LastName: "Lee", Password: "long enough"}, 1)
+	assert.ErrorIs(t, err, ErrAgentExists)
+
+	// A locked-out agent gets a new password and is unlocked
+	for i := 0; i < 3; i++ {
+		_, err = svc.policy.RecordLoginFailure(ctx, PasswordAccountAgent, "ann")
+		require.NoError(t, err)
+	}
+	require.NoError(t, db.QueryRow(`SELECT valid_id FROM users WHERE id = ?`, id).Scan(&validID))
+	assert.Equal(t, validTemporarilyInvalid, validID)
+	require.ErrorAs(t, svc.SetPassword(ctx, "ann", "long enough", 1), &policyErr)
+	assert.Equal(t, "history", policyErr.Code)
+	require.NoError(t, svc.SetPassword(ctx, "ann", "even longer one", 1))
+	require.NoError(t, db.QueryRow(`SELECT pw FROM users WHERE id = ?`, id).Scan(&pw))
+	assert.True(t, auth.NewPasswordHasher().VerifyPassword("even longer one", pw))
+	require.NoError(t, svc.Unlock(ctx, "ann", 1))
+	require.NoError(t, db.QueryRow(`SELECT valid_id FROM users WHERE id = ?`, id).Scan(&validID))
+	assert.Equal(t, 1, validID)
+	require.NoError(t, svc.Lock(ctx, "ann", 1))
+	require.NoError(t, db.QueryRow(`SELECT valid_id FROM users WHERE id = ?`, id).Scan(&validID))
+	assert.Equal(t, validTemporarilyInvalid, validID)
+	assert.ErrorIs(t, svc.Lock(ctx, "bob", 1), ErrPasswordAccountNotFound)
+
+	require.NoError(t, svc.AddToGroup(ctx, "ann", "users", "", 1))
+	require.NoError(t, svc.AddToGroup(ctx, "ann", "users", "", 1), "adding twice does nothing")
+	require.NoError(t, svc.AddToGroup(ctx, "ann", "users", "ro", 1))
+	require.NoError(t, db.QueryRow(`SELECT COUNT(*) FROM group_user WHERE user_id = ?`, id).Scan(&memberships))
+	assert.Equal(t, 3, memberships)
+	assert.ErrorIs(t, svc.AddToGroup(ctx, "ann", "users", "all", 1), ErrGroupPermission)
+	assert.ErrorIs(t, svc.AddToGroup(ctx, "ann", "nope", "rw", 1), ErrGroupNotFound)
+}
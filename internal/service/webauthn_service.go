@@ -0,0 +1,149 @@
+package service
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/goatkit/goatflow/internal/auth"
+)
+
+// WebAuthnCredentialsKey is the preference key holding a user's registered
+// security keys as a JSON array.
+const WebAuthnCredentialsKey = "UserWebAuthnCredentials"
+
+// maxWebAuthnCredentials caps how many keys one account may register.
+const maxWebAuthnCredentials = 10
+
+var (
+	ErrWebAuthnCredentialNotFound = errors.New("security key not found")
+	ErrWebAuthnCredentialExists   = errors.New("security key is already registered")
+	ErrWebAuthnCredentialLimit    = errors.New("too many security keys registered")
+)
+
+// WebAuthnService stores WebAuthn credentials for agents and customers in
+// the same preference tables as TOTP secrets.
+type WebAuthnService struct {
+	db      *sql.DB
+	backend PreferencesBackend
+}
+
+// NewWebAuthnService creates an unbound service; call ForUser or ForCustomer.
+func NewWebAuthnService(db *sql.DB) *WebAuthnService {
+	return &WebAuthnService{db: db}
+}
+
+// ForUser returns a WebAuthnService bound to a specific agent user ID.
+func (s *WebAuthnService) ForUser(userID int) *WebAuthnService {
+	return &WebAuthnService{db: s.db, backend: NewUserPreferencesBackend(s.db, userID)}
+}
+
+// ForCustomer returns a WebAuthnService bound to a specific customer login.
+func (s *WebAuthnService) ForCustomer(userLogin string) *WebAuthnService {
+	return &WebAuthnService{db: s.db, backend: NewCustomerPreferencesBackend(s.db, userLogin)}
+}
+
+// Credentials returns the registered credentials, oldest first.
+func (s *WebAuthnService) Credentials() ([]auth.WebAuthnCredential, error) {
+	if s.backend == nil {
+		return nil, errors.New("webauthn service is not bound to a user")
+	}
+	raw, err := s.backend.Get(WebAuthnCredentialsKey)
+	if err != nil {
+		return nil, err
+	}
+	if raw == "" {
+		return nil, nil
+	}
+	var creds []auth.WebAuthnCredential
+	if err := json.Unmarshal([]byte(raw), &creds); err != nil {
+		return nil, fmt.Errorf("failed to decode security keys: %w", err)
+	}
+	return creds, nil
+}
+
+// IsEnabled reports whether at least one security key is registered.
+func (s *WebAuthnService) IsEnabled() bool {
+	creds, err := s.Credentials()
+	return err == nil && len(creds) > 0
+}
+
+// AddCredential stores a newly registered credential under the given name.
+func (s *WebAuthnService) AddCredential(cred *auth.WebAuthnCredential, name string) error {
+	creds, err := s.Credentials()
+	if err != nil {
+		return err
+	}
+	if auth.FindWebAuthnCredential(creds, cred.ID) != nil {
+		return ErrWebAuthnCredentialExists
+	}
+	if len(creds) >= maxWebAuthnCredentials {
+		return ErrWebAuthnCredentialLimit
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = fmt.Sprintf("Security key %d", len(creds)+1)
+	}
+	if r := []rune(name); len(r) > 64 {
+		name = string(r[:64])
+	}
+	cred.Name = name
+	return s.save(append(creds, *cred))
+}
+
+// RecordUse stores the new signature counter after a successful login.
+func (s *WebAuthnService) RecordUse(id string, signCount uint32) error {
+	creds, err := s.Credentials()
+	if err != nil {
+		return err
+	}
+	cred := auth.FindWebAuthnCredential(creds, id)
+	if cred == nil {
+		return ErrWebAuthnCredentialNotFound
+	}
+	now := time.Now().UTC()
+	cred.SignCount = signCount
+	cred.LastUsedAt = &now
+	return s.save(creds)
+}
+
+// RemoveCredential deletes one credential by its base64url ID.
+func (s *WebAuthnService) RemoveCredential(id string) error {
+	creds, err := s.Credentials()
+	if err != nil {
+		return err
+	}
+	target := auth.FindWebAuthnCredential(creds, id)
+	if target == nil {
+		return ErrWebAuthnCredentialNotFound
+	}
+	kept := make([]auth.WebAuthnCredential, 0, len(creds)-1)
+	for i := range creds {
+		if &creds[i] != target {
+			kept = append(kept, creds[i])
+		}
+	}
+	if len(kept) == 0 {
+		return s.backend.Delete(WebAuthnCredentialsKey)
+	}
+	return s.save(kept)
+}
+
+// RemoveAll deletes every credential; used by the admin 2FA reset.
+func (s *WebAuthnService) RemoveAll() error {
+	if s.backend == nil {
+		return errors.New("webauthn service is not bound to a user")
+	}
+	return s.backend.Delete(WebAuthnCredentialsKey)
+}
+
+func (s *WebAuthnService) save(creds []auth.WebAuthnCredential) error {
+	data, err := json.Marshal(creds)
+	if err != nil {
+		return err
+	}
+	return s.backend.Set(WebAuthnCredentialsKey, string(data))
+}
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/auth"
+	"github.com/goatkit/goatflow/internal/testutil"
+)
+
+func newWebAuthnTestService(t *testing.T) *WebAuthnService {
+	t.Helper()
+	db := testutil.MigratedDB(t)
+	return NewWebAuthnService(db)
+}
+
+func TestWebAuthnService_CredentialLifecycle(t *testing.T) {
+	svc := newWebAuthnTestService(t)
+	agent := svc.ForUser(7)
+	assert.False(t, agent.IsEnabled())
+
+	require.NoError(t, agent.AddCredential(&auth.WebAuthnCredential{ID: "AQID", PublicKey: []byte{1}}, "  YubiKey  "))
+	require.NoError(t, agent.AddCredential(&auth.WebAuthnCredential{ID: "BAUG", PublicKey: []byte{2}}, ""))
+	assert.ErrorIs(t, agent.AddCredential(&auth.WebAuthnCredential{ID: "AQID"}, "dup"), ErrWebAuthnCredentialExists)
+	assert.True(t, agent.IsEnabled())
+
+	creds, err := agent.Credentials()
+	require.NoError(t, err)
+	require.Len(t, creds, 2)
+	assert.Equal(t, "YubiKey", creds[0].Name)
+	assert.Equal(t, "Security key 2", creds[1].Name)
+
+	require.NoError(t, agent.RecordUse("BAUG", 42))
+	creds, _ = agent.Credentials()
+	assert.Equal(t, uint32(42), creds[1].SignCount)
+	assert.NotNil(t, creds[1].LastUsedAt)
+
+	// Credentials are per account.
+	assert.False(t, svc.ForUser(8).IsEnabled())
+	assert.False(t, svc.ForCustomer("cust@example.com").IsEnabled())
+
+	require.NoError(t, agent.RemoveCredential("AQID"))
+	assert.ErrorIs(t, agent.RemoveCredential("AQID"), ErrWebAuthnCredentialNotFound)
+	creds, _ = agent.Credentials()
+	require.Len(t, creds, 1)
+	assert.Equal(t, "BAUG", creds[0].ID)
+
+	require.NoError(t, agent.RemoveCredential("BAUG"))
+	assert.False(t, agent.IsEnabled())
+}
+
+func TestWebAuthnService_CustomerAndLimits(t *testing.T) {
+	svc := newWebAuthnTestService(t).ForCustomer("cust@example.com")
+	for i := 0; i < maxWebAuthnCredentials; i++ {
+		id := strings.Repeat("A", 4*(i+1))
+		require.NoError(t, svc.AddCredential(&auth.WebAuthnCredential{ID: id}, strings.Repeat("ü", 100)))
+	}
+	assert.ErrorIs(t, svc.AddCredential(&auth.WebAuthnCredential{ID: "Zm9v"}, ""), ErrWebAuthnCredentialLimit)
+
+	creds, err := svc.Credentials()
+	require.NoError(t, err)
+	assert.Equal(t, 64, len([]rune(creds[0].Name)))
+
+	require.NoError(t, svc.RemoveAll())
+	assert.False(t, svc.IsEnabled())
+	_, err = NewWebAuthnService(nil).Credentials()
+	assert.Error(t, err, "unbound service")
+}
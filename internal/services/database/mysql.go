@@ -11,6 +11,7 @@ import (
 	_ "github.com/go-sql-driver/mysql"
 
 	"github.com/goatkit/goatflow/internal/services/registry"
+	"github.com/goatkit/goatflow/internal/tracing"
 )
 
 // MySQLService implements DatabaseService for MySQL.
@@ -48,7 +49,7 @@ func (s *MySQLService) Connect(ctx context.Context) error {
 
 	connStr := buildMySQLConnectionString(cfg)
 
-	db, err := sql.Open("mysql", connStr)
+	db, err := tracing.OpenDB("mysql", connStr)
 	if err != nil {
 		s.mu.Lock()
 		s.health.Status = registry.StatusUnhealthy
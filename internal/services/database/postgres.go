@@ -10,6 +10,7 @@ import (
 	_ "github.com/lib/pq"
 
 	"github.com/goatkit/goatflow/internal/services/registry"
+	"github.com/goatkit/goatflow/internal/tracing"
 )
 
 // PostgresService implements DatabaseService for PostgreSQL.
@@ -47,7 +48,7 @@ func (s *PostgresService) Connect(ctx context.Context) error {
 
 	connStr := buildPostgresConnectionString(cfg)
 
-	db, err := sql.Open("postgres", connStr)
+	db, err := tracing.OpenDB("postgres", connStr)
 	if err != nil {
 		s.mu.Lock()
 		s.health.Status = registry.StatusUnhealthy
@@ -0,0 +1,372 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/goatkit/goatflow/internal/services/registry"
+	"github.com/goatkit/goatflow/internal/tracing"
+)
+
+// SQLiteService implements DatabaseService for SQLite.
+type SQLiteService struct {
+	mu      sync.RWMutex
+	config  *registry.ServiceConfig
+	db      *sql.DB
+	health  *registry.ServiceHealth
+	metrics *registry.ServiceMetrics
+}
+
+// NewSQLiteService creates a new SQLite service instance.
+func NewSQLiteService(config *registry.ServiceConfig) (DatabaseService, error) {
+	return &SQLiteService{
+		config: config,
+		health: &registry.ServiceHealth{
+			ServiceID: config.ID,
+			Status:    registry.StatusInitializing,
+		},
+		metrics: &registry.ServiceMetrics{
+			ServiceID: config.ID,
+		},
+	}, nil
+}
+
+// Connect establishes connection to SQLite.
+func (s *SQLiteService) Connect(ctx context.Context) error {
+	s.mu.RLock()
+	cfg := s.config
+	s.mu.RUnlock()
+
+	if cfg == nil {
+		return fmt.Errorf("service configuration not set")
+	}
+
+	connStr := buildSQLiteConnectionString(cfg)
+
+	db, err := tracing.OpenDB("sqlite3", connStr)
+	if err != nil {
+		s.mu.Lock()
+		s.health.Status = registry.StatusUnhealthy
+		s.health.Error = err.Error()
+		s.mu.Unlock()
+		return fmt.Errorf("failed to open database: %w", err)
+	}
+
+	if cfg.MaxConns > 0 {
+		db.SetMaxOpenConns(cfg.MaxConns)
+	}
+	// An in-memory database lives only as long as one of its connections,
+	// so connections are kept open instead of being recycled.
+	db.SetMaxIdleConns(max(cfg.MinConns, 1))
+
+	pingCtx, cancel := context.WithTimeout(ctx, 3*time.Second)
+	defer cancel()
+
+	if err := db.PingContext(pingCtx); err != nil {
+		s.mu.Lock()
+		s.health.Status = registry.StatusUnhealthy
+		s.health.Error = err.Error()
+		s.mu.Unlock()
+		return fmt.Errorf("failed to ping database: %w", err)
+	}
+
+	s.mu.Lock()
+	if s.db != nil {
+		_ = s.db.Close()
+	}
+	s.db = db
+	s.health.Status = registry.StatusHealthy
+	s.health.Error = ""
+	s.health.LastChecked = time.Now()
+	s.mu.Unlock()
+
+	return nil
+}
+
+// Disconnect closes the database connection.
+func (s *SQLiteService) Disconnect(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.db != nil {
+		err := s.db.Close()
+		s.db = nil
+		s.health.Status = registry.StatusUnhealthy
+		s.health.Error = ""
+		return err
+	}
+	return nil
+}
+
+// GetDB returns the underlying database connection.
+func (s *SQLiteService) GetDB() *sql.DB {
+	s.mu.RLock()
+	db := s.db
+	s.mu.RUnlock()
+
+	if db == nil {
+		if err := s.Connect(context.Background()); err != nil {
+			return nil
+		}
+		s.mu.RLock()
+		db = s.db
+		s.mu.RUnlock()
+	}
+
+	return db
+}
+
+// Health returns the service health status.
+func (s *SQLiteService) Health(ctx context.Context) (*registry.ServiceHealth, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.db != nil {
+		if err := s.db.PingContext(ctx); err != nil {
+			s.health.Status = registry.StatusUnhealthy
+			s.health.Error = err.Error()
+			s.health.LastChecked = time.Now()
+			return s.health, err
+		}
+		s.health.Status = registry.StatusHealthy
+		s.health.Error = ""
+		s.health.LastChecked = time.Now()
+	}
+
+	return s.health, nil
+}
+
+// Metrics returns service metrics.
+func (s *SQLiteService) Metrics(ctx context.Context) (*registry.ServiceMetrics, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.db != nil {
+		stats := s.db.Stats()
+		s.metrics.Connections = stats.OpenConnections
+	}
+
+	return s.metrics, nil
+}
+
+// Ping tests the database connection.
+func (s *SQLiteService) Ping(ctx context.Context) error {
+	s.mu.RLock()
+	db := s.db
+	s.mu.RUnlock()
+
+	if db == nil {
+		return fmt.Errorf("database connection not established")
+	}
+
+	return db.PingContext(ctx)
+}
+
+// GetConfig returns the service configuration.
+func (s *SQLiteService) GetConfig() *registry.ServiceConfig {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.config
+}
+
+// UpdateConfig updates the service configuration.
+func (s *SQLiteService) UpdateConfig(config *registry.ServiceConfig) error {
+	if config == nil {
+		return fmt.Errorf("config cannot be nil")
+	}
+
+	s.mu.Lock()
+	s.config = config
+	s.health.ServiceID = config.ID
+	s.health.Status = registry.StatusInitializing
+	s.metrics.ServiceID = config.ID
+	s.mu.Unlock()
+
+	return s.Connect(context.Background())
+}
+
+// Type returns the service type.
+func (s *SQLiteService) Type() registry.ServiceType {
+	return registry.ServiceTypeDatabase
+}
+
+// Provider returns the service provider.
+func (s *SQLiteService) Provider() registry.ServiceProvider {
+	return registry.ProviderSQLite
+}
+
+// ID returns the service ID.
+func (s *SQLiteService) ID() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.config == nil {
+		return ""
+	}
+
+	return s.config.ID
+}
+
+// Query executes a query that returns rows.
+func (s *SQLiteService) Query(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	s.mu.RLock()
+	db := s.db
+	s.mu.RUnlock()
+
+	if db == nil {
+		return nil, fmt.Errorf("database connection not established")
+	}
+
+	return db.QueryContext(ctx, query, args...)
+}
+
+// QueryRow executes a query that returns at most one row.
+func (s *SQLiteService) QueryRow(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	s.mu.RLock()
+	db := s.db
+	s.mu.RUnlock()
+
+	if db == nil {
+		return nil
+	}
+
+	return db.QueryRowContext(ctx, query, args...)
+}
+
+// Exec executes a query without returning any rows.
+func (s *SQLiteService) Exec(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	s.mu.RLock()
+	db := s.db
+	s.mu.RUnlock()
+
+	if db == nil {
+		return nil, fmt.Errorf("database connection not established")
+	}
+
+	return db.ExecContext(ctx, query, args...)
+}
+
+// BeginTx starts a database transaction.
+func (s *SQLiteService) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
+	s.mu.RLock()
+	db := s.db
+	s.mu.RUnlock()
+
+	if db == nil {
+		return nil, fmt.Errorf("database connection not established")
+	}
+
+	return db.BeginTx(ctx, opts)
+}
+
+// SetMaxOpenConns sets the maximum number of open connections.
+func (s *SQLiteService) SetMaxOpenConns(n int) {
+	s.mu.RLock()
+	db := s.db
+	s.mu.RUnlock()
+
+	if db != nil {
+		db.SetMaxOpenConns(n)
+	}
+}
+
+// SetMaxIdleConns sets the maximum number of idle connections.
+func (s *SQLiteService) SetMaxIdleConns(n int) {
+	s.mu.RLock()
+	db := s.db
+	s.mu.RUnlock()
+
+	if db != nil {
+		db.SetMaxIdleConns(n)
+	}
+}
+
+// SetConnMaxLifetime sets the maximum lifetime of connections.
+func (s *SQLiteService) SetConnMaxLifetime(d time.Duration) {
+	s.mu.RLock()
+	db := s.db
+	s.mu.RUnlock()
+
+	if db != nil {
+		db.SetConnMaxLifetime(d)
+	}
+}
+
+// RunMigrations runs database migrations.
+func (s *SQLiteService) RunMigrations(ctx context.Context, migrationsPath string) error {
+	// SQLite schemas are migrated by database.RunMigrations, which
+	// translates the PostgreSQL migrations.
+	return fmt.Errorf("migrations not yet implemented for SQLite service")
+}
+
+// GetSchemaVersion returns the current schema version.
+func (s *SQLiteService) GetSchemaVersion() (int, error) {
+	s.mu.RLock()
+	db := s.db
+	s.mu.RUnlock()
+
+	if db == nil {
+		return 0, fmt.Errorf("database connection not established")
+	}
+
+	var version int
+	err := db.QueryRow("SELECT version FROM schema_migrations LIMIT 1").Scan(&version)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return version, err
+}
+
+// Backup writes a consistent copy of the database to path.
+func (s *SQLiteService) Backup(ctx context.Context, path string) error {
+	s.mu.RLock()
+	db := s.db
+	s.mu.RUnlock()
+
+	if db == nil {
+		return fmt.Errorf("database connection not established")
+	}
+
+	_, err := db.ExecContext(ctx, "VACUUM INTO ?", path)
+	return err
+}
+
+// Restore restores a database from backup.
+func (s *SQLiteService) Restore(ctx context.Context, path string) error {
+	// Replace the database file while the service is disconnected instead.
+	return fmt.Errorf("restore not yet implemented for SQLite")
+}
+
+// buildSQLiteConnectionString builds the go-sqlite3 DSN. The database name
+// is a file path, or :memory: for a throwaway database.
+func buildSQLiteConnectionString(config *registry.ServiceConfig) string {
+	if config == nil {
+		return ""
+	}
+
+	path := config.Database
+	if config.Options != nil {
+		if url, ok := config.Options["connection_url"].(string); ok && url != "" {
+			if strings.HasPrefix(url, "file:") {
+				return url
+			}
+			path = strings.TrimPrefix(strings.TrimPrefix(url, "sqlite3://"), "sqlite://")
+		}
+	}
+
+	if path == "" {
+		path = "goatflow.db"
+	}
+	if path == ":memory:" {
+		// Every connection in the pool must see the same database
+		return "file::memory:?cache=shared&_foreign_keys=on&_busy_timeout=5000"
+	}
+	return "file:" + path + "?_foreign_keys=on&_busy_timeout=5000&_journal_mode=WAL"
+}
@@ -5,6 +5,7 @@ package genericagent
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"strings"
@@ -13,6 +14,7 @@ import (
 	"github.com/goatkit/goatflow/internal/database"
 	"github.com/goatkit/goatflow/internal/models"
 	"github.com/goatkit/goatflow/internal/repository"
+	"github.com/goatkit/goatflow/internal/service"
 	"github.com/goatkit/goatflow/internal/ticketutil"
 )
 
@@ -164,12 +166,28 @@ func (s *Service) ExecuteJob(ctx context.Context, job *models.GenericAgentJob, u
 		return nil
 	}
 
+	// Skip tickets a dry run would report as blocked
+	plan, err := service.NewBulkChangeService(s.db).Plan(ctx, userID, bulkChange(actions))
+	if err != nil {
+		return fmt.Errorf("job %q: %w", job.Name, err)
+	}
+
 	var processed int
 	for _, ticketID := range ticketIDs {
 		if ctx.Err() != nil {
 			return ctx.Err()
 		}
 
+		pred, err := plan.Check(ctx, ticketID)
+		if err != nil {
+			s.logger.Printf("genericagent: failed to check ticket %d: %v", ticketID, err)
+			continue
+		}
+		if pred.Outcome == service.BulkChangeBlocked {
+			s.logger.Printf("genericagent: job %q skipped ticket %d: %s", job.Name, ticketID, strings.Join(pred.Reasons, "; "))
+			continue
+		}
+
 		if err := s.applyActions(ctx, ticketID, actions, userID); err != nil {
 			s.logger.Printf("genericagent: failed to apply actions to ticket %d: %v", ticketID, err)
 			continue
@@ -187,6 +205,47 @@ func (s *Service) ExecuteJob(ctx context.Context, job *models.GenericAgentJob, u
 	return nil
 }
 
+// PreviewJob predicts what running job would do to each matching ticket
+// without changing anything. Like ExecuteJob, it acts as userID.
+func (s *Service) PreviewJob(ctx context.Context, job *models.GenericAgentJob, userID int) (*service.BulkChangePreview, error) {
+	if job == nil {
+		return nil, fmt.Errorf("job is nil")
+	}
+	ticketIDs, err := s.matchTickets(ctx, job)
+	if err != nil {
+		return nil, fmt.Errorf("failed to match tickets: %w", err)
+	}
+	actions := job.Actions()
+	plan, err := service.NewBulkChangeService(s.db).Plan(ctx, userID, bulkChange(actions))
+	if err != nil {
+		return nil, err
+	}
+	preview, err := plan.Preview(ctx, ticketIDs)
+	if err != nil {
+		return nil, err
+	}
+	if actions.Delete() {
+		for i := range preview.Tickets {
+			if preview.Tickets[i].Outcome != service.BulkChangeBlocked {
+				preview.Tickets[i].Warnings = append(preview.Tickets[i].Warnings, "ticket will be deleted")
+			}
+		}
+	}
+	return preview, nil
+}
+
+// bulkChange returns the field changes of actions that the bulk change
+// checks cover.
+func bulkChange(actions *models.GenericAgentActions) service.BulkChange {
+	return service.BulkChange{
+		StateID:       actions.NewStateID(),
+		QueueID:       actions.NewQueueID(),
+		PriorityID:    actions.NewPriorityID(),
+		OwnerID:       actions.NewOwnerID(),
+		ResponsibleID: actions.NewResponsibleID(),
+	}
+}
+
 // matchTickets finds tickets matching the job's criteria.
 func (s *Service) matchTickets(ctx context.Context, job *models.GenericAgentJob) ([]int, error) {
 	criteria := job.MatchCriteria()
@@ -389,6 +448,22 @@ func (s *Service) matchTickets(ctx context.Context, job *models.GenericAgentJob)
 	return ticketIDs, rows.Err()
 }
 
+// availableAgent returns the agent to give a ticket to instead of agentID
+// while it is out of office. It returns false when the agent is absent with
+// no available substitute, in which case the change is skipped.
+func (s *Service) availableAgent(ctx context.Context, ticketID, agentID int) (int, bool) {
+	resolved, err := service.NewOutOfOfficeService(s.db).ResolveAgent(ctx, agentID)
+	if errors.Is(err, service.ErrAgentOutOfOffice) {
+		s.logger.Printf("generic agent: ticket %d: not assigning to agent %d: %v", ticketID, agentID, err)
+		return 0, false
+	}
+	if err != nil {
+		s.logger.Printf("generic agent: ticket %d: out-of-office lookup for agent %d failed: %v", ticketID, agentID, err)
+		return agentID, true
+	}
+	return resolved, true
+}
+
 // applyActions applies the configured actions to a ticket.
 func (s *Service) applyActions(ctx context.Context, ticketID int, actions *models.GenericAgentActions, userID int) error {
 	now := s.now()
@@ -420,13 +495,17 @@ func (s *Service) applyActions(ctx context.Context, ticketID int, actions *model
 	}
 
 	if id := actions.NewOwnerID(); id != nil {
-		setClauses = append(setClauses, "user_id = ?")
-		args = append(args, *id)
+		if agentID, ok := s.availableAgent(ctx, ticketID, *id); ok {
+			setClauses = append(setClauses, "user_id = ?")
+			args = append(args, agentID)
+		}
 	}
 
 	if id := actions.NewResponsibleID(); id != nil {
-		setClauses = append(setClauses, "responsible_user_id = ?")
-		args = append(args, *id)
+		if agentID, ok := s.availableAgent(ctx, ticketID, *id); ok {
+			setClauses = append(setClauses, "responsible_user_id = ?")
+			args = append(args, agentID)
+		}
 	}
 
 	if id := actions.NewLockID(); id != nil {
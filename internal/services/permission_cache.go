@@ -0,0 +1,442 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"reflect"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/goatkit/goatflow/internal/database"
+)
+
+// DefaultPermissionCacheTTL bounds how long a permission change that does
+// not invalidate the cache, such as direct SQL, can go unnoticed.
+const DefaultPermissionCacheTTL = time.Minute
+
+// SharedCache is a cache shared by all instances, such as Valkey.
+// *cache.RedisCache implements it; Get returns the JSON stored by Set, or
+// nil when the key is missing.
+type SharedCache interface {
+	Get(ctx context.Context, key string) (interface{}, error)
+	Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error
+	Delete(ctx context.Context, key string) error
+	Clear(ctx context.Context, pattern string) error
+}
+
+// PermissionCacheOptions configures the permission cache.
+type PermissionCacheOptions struct {
+	// TTL defaults to DefaultPermissionCacheTTL.
+	TTL time.Duration
+	// Shared keeps the cache in Valkey so that invalidation reaches every
+	// instance; nil keeps it in process memory.
+	Shared SharedCache
+}
+
+// The cache holds the group permissions of each agent, customer user and
+// customer company, plus the queue directory that maps queues to groups.
+// It is off until EnablePermissionCache is called, so PermissionService
+// queries the database directly in tests and tools.
+var (
+	permCacheMu sync.RWMutex
+	permCache   *permissionCache
+)
+
+// EnablePermissionCache turns on caching for every PermissionService,
+// replacing any previous cache.
+func EnablePermissionCache(opts PermissionCacheOptions) {
+	ttl := opts.TTL
+	if ttl <= 0 {
+		ttl = DefaultPermissionCacheTTL
+	}
+	var store permissionStore = &memoryPermissionStore{ttl: ttl, entries: make(map[string]memoryPermissionEntry)}
+	if opts.Shared != nil {
+		store = &sharedPermissionStore{cache: opts.Shared, ttl: ttl}
+	}
+	permCacheMu.Lock()
+	permCache = &permissionCache{store: store, metrics: globalPermissionCacheMetrics()}
+	permCacheMu.Unlock()
+}
+
+// DisablePermissionCache turns caching off again.
+func DisablePermissionCache() {
+	permCacheMu.Lock()
+	permCache = nil
+	permCacheMu.Unlock()
+}
+
+func activePermissionCache() *permissionCache {
+	permCacheMu.RLock()
+	defer permCacheMu.RUnlock()
+	return permCache
+}
+
+// InvalidateUserPermissions drops the cached group permissions of an agent.
+// Call it after changing the agent's group_user or role_user rows.
+func InvalidateUserPermissions(userID int) {
+	invalidatePermissions(userPermissionKey(userID))
+}
+
+// InvalidateCustomerUserPermissions drops the cached group permissions of a
+// customer user. Call it after changing their group_customer_user rows.
+func InvalidateCustomerUserPermissions(login string) {
+	invalidatePermissions(customerUserPermissionKey(login))
+}
+
+// InvalidateCustomerCompanyPermissions drops the cached group permissions of
+// a customer company. Call it after changing its group_customer rows.
+func InvalidateCustomerCompanyPermissions(customerID string) {
+	invalidatePermissions(customerCompanyPermissionKey(customerID))
+}
+
+// InvalidateQueuePermissions drops the cached queue directory. Call it after
+// changing a queue's group or validity; new queues are picked up without it.
+func InvalidateQueuePermissions() {
+	invalidatePermissions(queueDirectoryKey)
+}
+
+// InvalidateAllPermissions empties the cache. Call it after changes that
+// affect many principals at once, such as replacing a group's members or
+// changing a role's group permissions or validity.
+func InvalidateAllPermissions() {
+	c := activePermissionCache()
+	if c == nil {
+		return
+	}
+	c.generation.Add(1)
+	if err := c.store.removeAll(context.Background()); err != nil {
+		log.Printf("permission cache: invalidate all: %v", err)
+	}
+}
+
+func invalidatePermissions(key string) {
+	c := activePermissionCache()
+	if c == nil {
+		return
+	}
+	c.generation.Add(1)
+	if err := c.store.remove(context.Background(), key); err != nil {
+		log.Printf("permission cache: invalidate %s: %v", key, err)
+	}
+}
+
+func userPermissionKey(userID int) string {
+	return "user:" + strconv.Itoa(userID)
+}
+
+func customerUserPermissionKey(login string) string {
+	return "customer_user:" + login
+}
+
+func customerCompanyPermissionKey(customerID string) string {
+	return "customer_company:" + customerID
+}
+
+const queueDirectoryKey = "queues"
+
+// groupPermissions maps group IDs to permission keys.
+type groupPermissions map[int][]string
+
+// allows reports whether keys on groupID include one of want.
+func (g groupPermissions) allows(groupID int, want ...string) bool {
+	for _, have := range g[groupID] {
+		for _, w := range want {
+			if have == w {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// queueEntry is a queue's group and whether the queue is valid.
+type queueEntry struct {
+	GroupID int  `json:"group_id"`
+	Valid   bool `json:"valid"`
+}
+
+// queueDirectory maps queue IDs to their group.
+type queueDirectory map[int]queueEntry
+
+type permissionCache struct {
+	store   permissionStore
+	metrics *permissionCacheMetrics
+	// generation changes on every invalidation; values read from the
+	// database before an invalidation are not stored after it.
+	generation atomic.Uint64
+}
+
+// cached loads key into dest from the cache, or from load on a miss.
+func (c *permissionCache) cached(key string, dest interface{}, load func() (interface{}, error)) error {
+	ctx := context.Background()
+	found, err := c.store.load(ctx, key, dest)
+	if err != nil {
+		log.Printf("permission cache: read %s: %v", key, err)
+	}
+	if found {
+		c.metrics.hit()
+		return nil
+	}
+	c.metrics.miss()
+
+	gen := c.generation.Load()
+	value, err := load()
+	if err != nil {
+		return err
+	}
+	reflect.ValueOf(dest).Elem().Set(reflect.ValueOf(value))
+	if c.generation.Load() == gen {
+		if err := c.store.save(ctx, key, value); err != nil {
+			log.Printf("permission cache: write %s: %v", key, err)
+		}
+	}
+	return nil
+}
+
+func (c *permissionCache) userGroups(db *sql.DB, userID int) (groupPermissions, error) {
+	var perms groupPermissions
+	err := c.cached(userPermissionKey(userID), &perms, func() (interface{}, error) {
+		return loadGroupPermissions(db, `SELECT group_id, permission_key FROM `+effectiveGroupUser+` gu WHERE user_id = ?`, userID)
+	})
+	return perms, err
+}
+
+func (c *permissionCache) customerUserGroups(db *sql.DB, login string) (groupPermissions, error) {
+	var perms groupPermissions
+	err := c.cached(customerUserPermissionKey(login), &perms, func() (interface{}, error) {
+		return loadGroupPermissions(db, `SELECT group_id, permission_key FROM group_customer_user WHERE user_id = ?`, login)
+	})
+	return perms, err
+}
+
+func (c *permissionCache) customerCompanyGroups(db *sql.DB, customerID string) (groupPermissions, error) {
+	var perms groupPermissions
+	err := c.cached(customerCompanyPermissionKey(customerID), &perms, func() (interface{}, error) {
+		return loadGroupPermissions(db, `SELECT group_id, permission_key FROM group_customer WHERE customer_id = ?`, customerID)
+	})
+	return perms, err
+}
+
+func (c *permissionCache) queues(db *sql.DB) (queueDirectory, error) {
+	var dir queueDirectory
+	err := c.cached(queueDirectoryKey, &dir, func() (interface{}, error) { return loadQueueDirectory(db) })
+	return dir, err
+}
+
+// queueGroup returns the group of queueID, or false when there is no such
+// queue. A queue missing from the cached directory, such as one created
+// since, reloads it.
+func (c *permissionCache) queueGroup(db *sql.DB, queueID int) (int, bool, error) {
+	dir, err := c.queues(db)
+	if err != nil {
+		return 0, false, err
+	}
+	if q, ok := dir[queueID]; ok {
+		return q.GroupID, true, nil
+	}
+	gen := c.generation.Load()
+	if dir, err = loadQueueDirectory(db); err != nil {
+		return 0, false, err
+	}
+	if c.generation.Load() == gen {
+		if err := c.store.save(context.Background(), queueDirectoryKey, dir); err != nil {
+			log.Printf("permission cache: write %s: %v", queueDirectoryKey, err)
+		}
+	}
+	q, ok := dir[queueID]
+	return q.GroupID, ok, nil
+}
+
+func loadGroupPermissions(db *sql.DB, query string, principal interface{}) (groupPermissions, error) {
+	rows, err := db.Query(database.ConvertPlaceholders(query), principal)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load group permissions: %w", err)
+	}
+	defer rows.Close()
+
+	perms := groupPermissions{}
+	for rows.Next() {
+		var groupID int
+		var key string
+		if err := rows.Scan(&groupID, &key); err != nil {
+			return nil, fmt.Errorf("failed to scan group permission: %w", err)
+		}
+		perms[groupID] = append(perms[groupID], key)
+	}
+	return perms, rows.Err()
+}
+
+func loadQueueDirectory(db *sql.DB) (queueDirectory, error) {
+	rows, err := db.Query(`SELECT id, group_id, valid_id FROM queue`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load queues: %w", err)
+	}
+	defer rows.Close()
+
+	dir := queueDirectory{}
+	for rows.Next() {
+		var id, groupID, validID int
+		if err := rows.Scan(&id, &groupID, &validID); err != nil {
+			return nil, fmt.Errorf("failed to scan queue: %w", err)
+		}
+		dir[id] = queueEntry{GroupID: groupID, Valid: validID == 1}
+	}
+	return dir, rows.Err()
+}
+
+// permissionStore is where the permission cache keeps its entries.
+type permissionStore interface {
+	// load stores the value of key in dest, which points to a value of
+	// the type that was saved.
+	load(ctx context.Context, key string, dest interface{}) (bool, error)
+	save(ctx context.Context, key string, value interface{}) error
+	remove(ctx context.Context, key string) error
+	removeAll(ctx context.Context) error
+}
+
+type memoryPermissionEntry struct {
+	value   interface{}
+	expires time.Time
+}
+
+// memoryPermissionStore keeps entries in process memory. Cached values are
+// shared between callers and must not be modified.
+type memoryPermissionStore struct {
+	ttl     time.Duration
+	mu      sync.RWMutex
+	entries map[string]memoryPermissionEntry
+}
+
+func (m *memoryPermissionStore) load(_ context.Context, key string, dest interface{}) (bool, error) {
+	m.mu.RLock()
+	e, ok := m.entries[key]
+	m.mu.RUnlock()
+	if !ok || time.Now().After(e.expires) {
+		return false, nil
+	}
+	reflect.ValueOf(dest).Elem().Set(reflect.ValueOf(e.value))
+	return true, nil
+}
+
+func (m *memoryPermissionStore) save(_ context.Context, key string, value interface{}) error {
+	now := time.Now()
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	// Entries of principals that stopped making requests are dropped once
+	// enough of them have piled up.
+	if len(m.entries) >= 10000 {
+		for k, e := range m.entries {
+			if now.After(e.expires) {
+				delete(m.entries, k)
+			}
+		}
+	}
+	m.entries[key] = memoryPermissionEntry{value: value, expires: now.Add(m.ttl)}
+	return nil
+}
+
+func (m *memoryPermissionStore) remove(_ context.Context, key string) error {
+	m.mu.Lock()
+	delete(m.entries, key)
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *memoryPermissionStore) removeAll(context.Context) error {
+	m.mu.Lock()
+	m.entries = make(map[string]memoryPermissionEntry)
+	m.mu.Unlock()
+	return nil
+}
+
+// sharedPermissionStore keeps entries as JSON in a SharedCache.
+type sharedPermissionStore struct {
+	cache SharedCache
+	ttl   time.Duration
+}
+
+const sharedPermissionPrefix = "permissions:"
+
+func (s *sharedPermissionStore) load(ctx context.Context, key string, dest interface{}) (bool, error) {
+	v, err := s.cache.Get(ctx, sharedPermissionPrefix+key)
+	if err != nil || v == nil {
+		return false, err
+	}
+	data, ok := v.([]byte)
+	if !ok {
+		return false, fmt.Errorf("unexpected cache payload %T", v)
+	}
+	if err := json.Unmarshal(data, dest); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *sharedPermissionStore) save(ctx context.Context, key string, value interface{}) error {
+	return s.cache.Set(ctx, sharedPermissionPrefix+key, value, s.ttl)
+}
+
+func (s *sharedPermissionStore) remove(ctx context.Context, key string) error {
+	return s.cache.Delete(ctx, sharedPermissionPrefix+key)
+}
+
+func (s *sharedPermissionStore) removeAll(ctx context.Context) error {
+	return s.cache.Clear(ctx, sharedPermissionPrefix+"*")
+}
+
+type permissionCacheMetrics struct {
+	requests     *prometheus.CounterVec
+	hits, misses atomic.Uint64
+}
+
+var (
+	permissionCacheMetricsOnce sync.Once
+	permissionCacheMetricsInst *permissionCacheMetrics
+)
+
+func globalPermissionCacheMetrics() *permissionCacheMetrics {
+	permissionCacheMetricsOnce.Do(func() {
+		m := &permissionCacheMetrics{
+			requests: promauto.NewCounterVec(prometheus.CounterOpts{
+				Namespace: "goatflow",
+				Subsystem: "permission_cache",
+				Name:      "requests_total",
+				Help:      "Permission cache lookups, labeled by result (hit or miss)",
+			}, []string{"result"}),
+		}
+		promauto.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: "goatflow",
+			Subsystem: "permission_cache",
+			Name:      "hit_ratio",
+			Help:      "Share of permission cache lookups answered from the cache since startup",
+		}, m.hitRatio)
+		permissionCacheMetricsInst = m
+	})
+	return permissionCacheMetricsInst
+}
+
+func (m *permissionCacheMetrics) hit() {
+	m.hits.Add(1)
+	m.requests.WithLabelValues("hit").Inc()
+}
+
+func (m *permissionCacheMetrics) miss() {
+	m.misses.Add(1)
+	m.requests.WithLabelValues("miss").Inc()
+}
+
+func (m *permissionCacheMetrics) hitRatio() float64 {
+	hits, misses := m.hits.Load(), m.misses.Load()
+	if hits+misses == 0 {
+		return 0
+	}
+	return float64(hits) / float64(hits+misses)
+}
@@ -0,0 +1,294 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/testutil"
+)
+
+func newPermissionCacheTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	db := testutil.MigratedDB(t)
+	for _, stmt := range []string{
+		`INSERT INTO users (id, login, pw, first_name, last_name, valid_id, create_time, create_by, change_time, change_by) VALUES
+			(1, 'root@localhost', 'x', 'Admin', 'OTRS', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1),
+			(5, 'agent5', 'x', 'Agent', 'Five', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1),
+			(6, 'agent6', 'x', 'Agent', 'Six', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1),
+			(7, 'agent7', 'x', 'Agent', 'Seven', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+		`INSERT INTO groups (id, name, valid_id, create_time, create_by, change_time, change_by) VALUES
+			(10, 'first-line', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1),
+			(20, 'second-line', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1),
+			(30, 'retired', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+		// Queues 3 and 4 are invalid.
+		`UPDATE queue SET group_id = CASE id WHEN 1 THEN 10 WHEN 2 THEN 20 ELSE 30 END,
+			valid_id = CASE WHEN id <= 2 THEN 1 ELSE 2 END`,
+		`INSERT INTO ticket (id, tn, title, queue_id, ticket_lock_id, user_id, responsible_user_id,
+			ticket_priority_id, ticket_state_id, timeout, until_time, escalation_time, escalation_update_time,
+			escalation_response_time, escalation_solution_time, archive_flag, create_time, create_by, change_time, change_by) VALUES
+			(100, '1100', 'Printer', 1, 1, 1, 1, 3, 2, 0, 0, 0, 0, 0, 0, 0, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1),
+			(200, '1200', 'Printer', 2, 1, 1, 1, 3, 2, 0, 0, 0, 0, 0, 0, 0, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+		`INSERT INTO group_customer_user (user_id, group_id, permission_key, permission_value,
+			create_time, create_by, change_time, change_by)
+			VALUES ('jane@example.com', 20, 'ro', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+		`INSERT INTO customer_company (customer_id, name, parent_customer_id, valid_id, create_time, create_by, change_time, change_by) VALUES
+			('acme', 'Acme', NULL, 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1),
+			('acme-eu', 'Acme Europe', 'acme', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+		`INSERT INTO roles (id, name, valid_id, create_time, create_by, change_time, change_by) VALUES
+			(1, 'dispatch', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1),
+			(2, 'retired', 2, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+		`INSERT INTO role_user (user_id, role_id, create_time, create_by, change_time, change_by) VALUES
+			(7, 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1),
+			(7, 2, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+		`INSERT INTO group_role (role_id, group_id, permission_key, permission_value, create_time, create_by, change_time, change_by) VALUES
+			(1, 10, 'rw', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1),
+			(1, 20, 'note', 0, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1),
+			(2, 20, 'rw', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+	} {
+		_, err := db.Exec(stmt)
+		require.NoError(t, err, stmt)
+	}
+	for _, g := range []struct {
+		userID, groupID int
+		key             string
+	}{{5, 10, "rw"}, {5, 20, "ro"}, {5, 20, "note"}, {5, 30, "rw"}, {6, 20, "move_into"}} {
+		addTestGroupUser(t, db, g.userID, g.groupID, g.key)
+	}
+	addTestGroupCustomer(t, db, "acme", 10, "ro")
+	return db
+}
+
+func addTestGroupUser(t *testing.T, db *sql.DB, userID, groupID int, key string) {
+	t.Helper()
+	_, err := db.Exec(`INSERT INTO group_user (user_id, group_id, permission_key, create_time, create_by, change_time, change_by)
+		VALUES (?, ?, ?, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`, userID, groupID, key)
+	require.NoError(t, err)
+}
+
+func addTestGroupCustomer(t *testing.T, db *sql.DB, customerID string, groupID int, key string) {
+	t.Helper()
+	_, err := db.Exec(`INSERT INTO group_customer (customer_id, group_id, permission_key, permission_value, permission_context,
+		create_time, create_by, change_time, change_by) VALUES (?, ?, ?, 1, 'Ticket', CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+		customerID, groupID, key)
+	require.NoError(t, err)
+}
+
+func enableTestPermissionCache(t *testing.T, opts PermissionCacheOptions) *permissionCache {
+	t.Helper()
+	EnablePermissionCache(opts)
+	t.Cleanup(DisablePermissionCache)
+	return activePermissionCache()
+}
+
+// TestPermissionCache_MatchesDatabase runs every cached check with the
+// cache off and on and expects the same answers.
+func TestPermissionCache_MatchesDatabase(t *testing.T) {
+	db := newPermissionCacheTestDB(t)
+	svc := NewPermissionService(db)
+
+	type check struct {
+		name string
+		run  func() (interface{}, error)
+	}
+	checks := []check{
+		{"CanWriteQueue 5/1", func() (interface{}, error) { return svc.CanWriteQueue(5, 1) }},
+		{"CanWriteQueue 5/2", func() (interface{}, error) { return svc.CanWriteQueue(5, 2) }},
+		{"CanReadQueue 5/2", func() (interface{}, error) { return svc.CanReadQueue(5, 2) }},
+		{"CanReadQueue 6/2", func() (interface{}, error) { return svc.CanReadQueue(6, 2) }},
+		{"CanReadQueue 5/99", func() (interface{}, error) { return svc.CanReadQueue(5, 99) }},
+		{"CanWriteTicket 5/100", func() (interface{}, error) { return svc.CanWriteTicket(5, 100) }},
+		{"CanWriteTicket 5/200", func() (interface{}, error) { return svc.CanWriteTicket(5, 200) }},
+		{"CanReadTicket 5/999", func() (interface{}, error) { return svc.CanReadTicket(5, 999) }},
+		{"CanMoveInto 6/2", func() (interface{}, error) { return svc.CanMoveInto(6, 2) }},
+		{"CanWriteQueue 7/1 via role", func() (interface{}, error) { return svc.CanWriteQueue(7, 1) }},
+		{"CanReadQueue 7/2 invalid role", func() (interface{}, error) { return svc.CanReadQueue(7, 2) }},
+		{"GetUserQueuePermissions 7", func() (interface{}, error) { return svc.GetUserQueuePermissions(7) }},
+		{"CanCreate 6/2", func() (interface{}, error) { return svc.CanCreate(6, 2) }},
+		{"CanAddNote 5/200", func() (interface{}, error) { return svc.CanAddNote(5, 200) }},
+		{"GetUserQueuePermissions 5", func() (interface{}, error) { return svc.GetUserQueuePermissions(5) }},
+		{"GetUserQueuePermissions 6", func() (interface{}, error) { return svc.GetUserQueuePermissions(6) }},
+		{"CustomerCanAccessQueue 2", func() (interface{}, error) { return svc.CustomerCanAccessQueue("jane@example.com", 2) }},
+		{"CustomerCanAccessQueue 1", func() (interface{}, error) { return svc.CustomerCanAccessQueue("jane@example.com", 1) }},
+		{"CustomerCompanyCanAccessQueue", func() (interface{}, error) { return svc.CustomerCompanyCanAccessQueue("acme-eu", 1) }},
+		{"CustomerCompanyTreeCanAccessQueue", func() (interface{}, error) { return svc.CustomerCompanyTreeCanAccessQueue("acme-eu", 1) }},
+	}
+
+	want := make([]interface{}, len(checks))
+	for i, c := range checks {
+		got, err := c.run()
+		require.NoError(t, err, c.name)
+		want[i] = got
+	}
+
+	enableTestPermissionCache(t, PermissionCacheOptions{})
+	for round := 0; round < 2; round++ {
+		for i, c := range checks {
+			got, err := c.run()
+			require.NoError(t, err, c.name)
+			assert.Equal(t, want[i], got, "%s, round %d", c.name, round)
+		}
+	}
+}
+
+func TestPermissionCache_Invalidation(t *testing.T) {
+	db := newPermissionCacheTestDB(t)
+	svc := NewPermissionService(db)
+	enableTestPermissionCache(t, PermissionCacheOptions{})
+
+	ok, err := svc.CanWriteQueue(5, 2)
+	require.NoError(t, err)
+	require.False(t, ok)
+
+	addTestGroupUser(t, db, 5, 20, "rw")
+	ok, _ = svc.CanWriteQueue(5, 2)
+	assert.False(t, ok, "served from the cache until invalidated")
+
+	InvalidateUserPermissions(5)
+	ok, _ = svc.CanWriteQueue(5, 2)
+	assert.True(t, ok)
+
+	_, err = db.Exec(`UPDATE queue SET group_id = 30 WHERE id = 2`)
+	require.NoError(t, err)
+	InvalidateAllPermissions()
+	perms, err := svc.GetUserQueuePermissions(5)
+	require.NoError(t, err)
+	assert.Equal(t, map[int]string{1: "rw", 2: "rw"}, perms)
+
+	_, err = db.Exec(`DELETE FROM group_customer WHERE customer_id = 'acme'`)
+	require.NoError(t, err)
+	ok, _ = svc.CustomerCompanyTreeCanAccessQueue("acme-eu", 1)
+	require.False(t, ok, "a company that was never cached is read fresh")
+	addTestGroupCustomer(t, db, "acme", 10, "rw")
+	InvalidateCustomerCompanyPermissions("acme")
+	ok, _ = svc.CustomerCompanyTreeCanAccessQueue("acme-eu", 1)
+	assert.True(t, ok)
+}
+
+func TestPermissionCache_NewQueueReloadsDirectory(t *testing.T) {
+	db := newPermissionCacheTestDB(t)
+	svc := NewPermissionService(db)
+	enableTestPermissionCache(t, PermissionCacheOptions{})
+
+	ok, err := svc.CanWriteQueue(5, 1)
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	_, err = db.Exec(`INSERT INTO queue (id, name, group_id, system_address_id, salutation_id, signature_id,
+		unlock_timeout, follow_up_id, follow_up_lock, valid_id, create_time, create_by, change_time, change_by)
+		VALUES (5, 'New', 10, 1, 1, 1, 0, 1, 0, 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`)
+	require.NoError(t, err)
+	ok, err = svc.CanWriteQueue(5, 5)
+	require.NoError(t, err)
+	assert.True(t, ok)
+}
+
+func TestPermissionCache_TTL(t *testing.T) {
+	db := newPermissionCacheTestDB(t)
+	svc := NewPermissionService(db)
+	enableTestPermissionCache(t, PermissionCacheOptions{TTL: 20 * time.Millisecond})
+
+	ok, _ := svc.CanReadQueue(6, 1)
+	require.False(t, ok)
+	addTestGroupUser(t, db, 6, 10, "ro")
+
+	time.Sleep(30 * time.Millisecond)
+	ok, _ = svc.CanReadQueue(6, 1)
+	assert.True(t, ok)
+}
+
+func TestPermissionCache_HitRatio(t *testing.T) {
+	db := newPermissionCacheTestDB(t)
+	svc := NewPermissionService(db)
+	c := enableTestPermissionCache(t, PermissionCacheOptions{})
+	hits, misses := c.metrics.hits.Load(), c.metrics.misses.Load()
+
+	for i := 0; i < 3; i++ {
+		_, err := svc.CanReadQueue(5, 1)
+		require.NoError(t, err)
+	}
+
+	// The first check misses the queue directory and the user's groups;
+	// the other two hit both.
+	assert.Equal(t, uint64(4), c.metrics.hits.Load()-hits)
+	assert.Equal(t, uint64(2), c.metrics.misses.Load()-misses)
+	assert.Greater(t, c.metrics.hitRatio(), 0.0)
+}
+
+// fakeSharedCache stores JSON like *cache.RedisCache.
+type fakeSharedCache struct {
+	mu   sync.Mutex
+	data map[string][]byte
+}
+
+func (f *fakeSharedCache) Get(_ context.Context, key string) (interface{}, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if v, ok := f.data[key]; ok {
+		return v, nil
+	}
+	return nil, nil //nolint:nilnil
+}
+
+func (f *fakeSharedCache) Set(_ context.Context, key string, value interface{}, _ time.Duration) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	f.mu.Lock()
+	f.data[key] = data
+	f.mu.Unlock()
+	return nil
+}
+
+func (f *fakeSharedCache) Delete(_ context.Context, key string) error {
+	f.mu.Lock()
+	delete(f.data, key)
+	f.mu.Unlock()
+	return nil
+}
+
+func (f *fakeSharedCache) Clear(_ context.Context, pattern string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for k := range f.data {
+		if strings.HasPrefix(k, strings.TrimSuffix(pattern, "*")) {
+			delete(f.data, k)
+		}
+	}
+	return nil
+}
+
+func TestPermissionCache_SharedStore(t *testing.T) {
+	db := newPermissionCacheTestDB(t)
+	svc := NewPermissionService(db)
+	shared := &fakeSharedCache{data: map[string][]byte{}}
+	enableTestPermissionCache(t, PermissionCacheOptions{Shared: shared})
+
+	perms, err := svc.GetUserQueuePermissions(5)
+	require.NoError(t, err)
+	assert.Equal(t, map[int]string{1: "rw", 2: "ro"}, perms)
+	assert.Contains(t, shared.data, "permissions:user:5")
+	assert.Contains(t, shared.data, "permissions:queues")
+
+	// A second instance reads the entries the first one stored.
+	EnablePermissionCache(PermissionCacheOptions{Shared: shared})
+	_, err = db.Exec(`DELETE FROM group_user`)
+	require.NoError(t, err)
+	perms, err = svc.GetUserQueuePermissions(5)
+	require.NoError(t, err)
+	assert.Equal(t, map[int]string{1: "rw", 2: "ro"}, perms)
+
+	InvalidateAllPermissions()
+	assert.Empty(t, shared.data)
+	perms, err = svc.GetUserQueuePermissions(5)
+	require.NoError(t, err)
+	assert.Empty(t, perms)
+}
@@ -3,11 +3,28 @@ package services
 import (
 	"database/sql"
 	"fmt"
+	"strings"
 
 	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/internal/models"
 )
 
+// effectiveGroupUser has the columns of group_user and holds each agent's
+// direct group permissions plus those granted by their valid roles
+// (role_user -> group_role), as in OTRS.
+const effectiveGroupUser = `(
+	SELECT user_id, group_id, permission_key FROM group_user
+	UNION
+	SELECT ru.user_id, gr.group_id, gr.permission_key
+	FROM role_user ru
+	JOIN roles r ON r.id = ru.role_id
+	JOIN group_role gr ON gr.role_id = ru.role_id
+	WHERE r.valid_id = 1 AND gr.permission_value = 1
+)`
+
 // PermissionService handles permission checking for tickets and queues.
+// An agent's permissions are the union of direct group assignments and the
+// group permissions of their roles.
 type PermissionService struct {
 	db *sql.DB
 }
@@ -20,10 +37,13 @@ func NewPermissionService(db *sql.DB) *PermissionService {
 // CanWriteTicket checks if a user has write (rw) permission on a ticket's queue.
 // Returns true if the user has 'rw' permission on the queue's group.
 func (s *PermissionService) CanWriteTicket(userID int, ticketID int64) (bool, error) {
+	if c := activePermissionCache(); c != nil {
+		return s.cachedTicketAllows(c, userID, ticketID, "rw")
+	}
 	// Get the group_id for the ticket's queue, then check if user has 'rw' on that group
 	query := database.ConvertPlaceholders(`
 		SELECT EXISTS(
-			SELECT 1 FROM group_user gu
+			SELECT 1 FROM ` + effectiveGroupUser + ` gu
 			JOIN queue q ON gu.group_id = q.group_id
 			JOIN ticket t ON t.queue_id = q.id
 			WHERE t.id = ? 
@@ -43,9 +63,12 @@ func (s *PermissionService) CanWriteTicket(userID int, ticketID int64) (bool, er
 // CanReadTicket checks if a user has at least read (ro) permission on a ticket's queue.
 // Returns true if the user has 'ro' OR 'rw' permission on the queue's group.
 func (s *PermissionService) CanReadTicket(userID int, ticketID int64) (bool, error) {
+	if c := activePermissionCache(); c != nil {
+		return s.cachedTicketAllows(c, userID, ticketID, "ro", "rw")
+	}
 	query := database.ConvertPlaceholders(`
 		SELECT EXISTS(
-			SELECT 1 FROM group_user gu
+			SELECT 1 FROM ` + effectiveGroupUser + ` gu
 			JOIN queue q ON gu.group_id = q.group_id
 			JOIN ticket t ON t.queue_id = q.id
 			WHERE t.id = ? 
@@ -64,9 +87,12 @@ func (s *PermissionService) CanReadTicket(userID int, ticketID int64) (bool, err
 
 // CanWriteQueue checks if a user has write (rw) permission on a specific queue.
 func (s *PermissionService) CanWriteQueue(userID int, queueID int) (bool, error) {
+	if c := activePermissionCache(); c != nil {
+		return s.cachedQueueAllows(c, userID, queueID, "rw")
+	}
 	query := database.ConvertPlaceholders(`
 		SELECT EXISTS(
-			SELECT 1 FROM group_user gu
+			SELECT 1 FROM ` + effectiveGroupUser + ` gu
 			JOIN queue q ON gu.group_id = q.group_id
 			WHERE q.id = ? 
 			  AND gu.user_id = ?
@@ -84,9 +110,12 @@ func (s *PermissionService) CanWriteQueue(userID int, queueID int) (bool, error)
 
 // CanReadQueue checks if a user has at least read (ro) permission on a specific queue.
 func (s *PermissionService) CanReadQueue(userID int, queueID int) (bool, error) {
+	if c := activePermissionCache(); c != nil {
+		return s.cachedQueueAllows(c, userID, queueID, "ro", "rw")
+	}
 	query := database.ConvertPlaceholders(`
 		SELECT EXISTS(
-			SELECT 1 FROM group_user gu
+			SELECT 1 FROM ` + effectiveGroupUser + ` gu
 			JOIN queue q ON gu.group_id = q.group_id
 			WHERE q.id = ? 
 			  AND gu.user_id = ?
@@ -105,10 +134,13 @@ func (s *PermissionService) CanReadQueue(userID int, queueID int) (bool, error)
 // GetUserQueuePermissions returns all queues and permission levels for a user.
 // Map key is queue_id, value is the highest permission level ('rw' > 'ro').
 func (s *PermissionService) GetUserQueuePermissions(userID int) (map[int]string, error) {
+	if c := activePermissionCache(); c != nil {
+		return s.cachedUserQueuePermissions(c, userID)
+	}
 	query := database.ConvertPlaceholders(`
 		SELECT q.id, gu.permission_key
 		FROM queue q
-		JOIN group_user gu ON gu.group_id = q.group_id
+		JOIN ` + effectiveGroupUser + ` gu ON gu.group_id = q.group_id
 		WHERE gu.user_id = ?
 		  AND q.valid_id = 1
 		ORDER BY q.id, gu.permission_key DESC`)
@@ -142,9 +174,12 @@ func (s *PermissionService) GetUserQueuePermissions(userID int) (map[int]string,
 // HasPermission checks if a user has a specific permission on a queue.
 // Returns true if user has the exact permission OR has 'rw' (which supersedes all).
 func (s *PermissionService) HasPermission(userID int, queueID int, permKey string) (bool, error) {
+	if c := activePermissionCache(); c != nil {
+		return s.cachedQueueAllows(c, userID, queueID, permKey, "rw")
+	}
 	query := database.ConvertPlaceholders(`
 		SELECT EXISTS(
-			SELECT 1 FROM group_user gu
+			SELECT 1 FROM ` + effectiveGroupUser + ` gu
 			JOIN queue q ON gu.group_id = q.group_id
 			WHERE q.id = ? 
 			  AND gu.user_id = ?
@@ -162,9 +197,12 @@ func (s *PermissionService) HasPermission(userID int, queueID int, permKey strin
 
 // HasTicketPermission checks if a user has a specific permission on a ticket's queue.
 func (s *PermissionService) HasTicketPermission(userID int, ticketID int64, permKey string) (bool, error) {
+	if c := activePermissionCache(); c != nil {
+		return s.cachedTicketAllows(c, userID, ticketID, permKey, "rw")
+	}
 	query := database.ConvertPlaceholders(`
 		SELECT EXISTS(
-			SELECT 1 FROM group_user gu
+			SELECT 1 FROM ` + effectiveGroupUser + ` gu
 			JOIN queue q ON gu.group_id = q.group_id
 			JOIN ticket t ON t.queue_id = q.id
 			WHERE t.id = ? 
@@ -211,6 +249,78 @@ func (s *PermissionService) CanChangePriority(userID int, ticketID int64) (bool,
 	return s.HasTicketPermission(userID, ticketID, "priority")
 }
 
+// cachedQueueAllows reports whether the user holds one of keys on the
+// queue's group, using the permission cache.
+func (s *PermissionService) cachedQueueAllows(c *permissionCache, userID, queueID int, keys ...string) (bool, error) {
+	groupID, ok, err := c.queueGroup(s.db, queueID)
+	if err != nil || !ok {
+		return false, err
+	}
+	perms, err := c.userGroups(s.db, userID)
+	if err != nil {
+		return false, err
+	}
+	return perms.allows(groupID, keys...), nil
+}
+
+// cachedTicketAllows is cachedQueueAllows for the ticket's queue. Tickets
+// move between queues too often to cache, so the queue is looked up.
+func (s *PermissionService) cachedTicketAllows(c *permissionCache, userID int, ticketID int64, keys ...string) (bool, error) {
+	var queueID int
+	err := s.db.QueryRow(database.ConvertPlaceholders(`SELECT queue_id FROM ticket WHERE id = ?`), ticketID).Scan(&queueID)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to load ticket queue: %w", err)
+	}
+	return s.cachedQueueAllows(c, userID, queueID, keys...)
+}
+
+func (s *PermissionService) cachedUserQueuePermissions(c *permissionCache, userID int) (map[int]string, error) {
+	dir, err := c.queues(s.db)
+	if err != nil {
+		return nil, err
+	}
+	groups, err := c.userGroups(s.db, userID)
+	if err != nil {
+		return nil, err
+	}
+	perms := make(map[int]string)
+	for queueID, q := range dir {
+		if !q.Valid {
+			continue
+		}
+		// Same pick as ORDER BY permission_key DESC: rw, then ro, then
+		// the granular keys.
+		for _, key := range groups[q.GroupID] {
+			if key > perms[queueID] {
+				perms[queueID] = key
+			}
+		}
+	}
+	return perms, nil
+}
+
+// cachedCompaniesCanAccessQueue reports whether one of the companies has
+// ro or rw on the queue's group, using the permission cache.
+func (s *PermissionService) cachedCompaniesCanAccessQueue(c *permissionCache, customerIDs []string, queueID int) (bool, error) {
+	groupID, ok, err := c.queueGroup(s.db, queueID)
+	if err != nil || !ok {
+		return false, err
+	}
+	for _, id := range customerIDs {
+		perms, err := c.customerCompanyGroups(s.db, id)
+		if err != nil {
+			return false, err
+		}
+		if perms.allows(groupID, "ro", "rw") {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
 // =============================================================================
 // CUSTOMER GROUP PERMISSIONS
 // =============================================================================
@@ -218,6 +328,17 @@ func (s *PermissionService) CanChangePriority(userID int, ticketID int64) (bool,
 // CustomerCanAccessQueue checks if a customer (by login) has access to a queue via group_customer_user.
 // This is in addition to company-based access (customer_id on ticket).
 func (s *PermissionService) CustomerCanAccessQueue(customerLogin string, queueID int) (bool, error) {
+	if c := activePermissionCache(); c != nil {
+		groupID, ok, err := c.queueGroup(s.db, queueID)
+		if err != nil || !ok {
+			return false, err
+		}
+		perms, err := c.customerUserGroups(s.db, customerLogin)
+		if err != nil {
+			return false, err
+		}
+		return perms.allows(groupID, "ro", "rw"), nil
+	}
 	// Check if customer user has explicit group permission on the queue's group
 	query := database.ConvertPlaceholders(`
 		SELECT EXISTS(
@@ -239,6 +360,9 @@ func (s *PermissionService) CustomerCanAccessQueue(customerLogin string, queueID
 
 // CustomerCompanyCanAccessQueue checks if a customer's company has access to a queue via group_customer.
 func (s *PermissionService) CustomerCompanyCanAccessQueue(customerID string, queueID int) (bool, error) {
+	if c := activePermissionCache(); c != nil {
+		return s.cachedCompaniesCanAccessQueue(c, []string{customerID}, queueID)
+	}
 	query := database.ConvertPlaceholders(`
 		SELECT EXISTS(
 			SELECT 1 FROM group_customer gc
@@ -257,19 +381,126 @@ func (s *PermissionService) CustomerCompanyCanAccessQueue(customerID string, que
 	return hasAccess, nil
 }
 
+// CustomerCompanyTreeCanAccessQueue checks if a customer company, or any
+// company above it in the company hierarchy, has access to a queue via
+// group_customer. Permissions granted to a parent company apply to all of
+// its subsidiaries.
+func (s *PermissionService) CustomerCompanyTreeCanAccessQueue(customerID string, queueID int) (bool, error) {
+	lineage, err := s.customerCompanyLineage(customerID)
+	if err != nil {
+		return false, err
+	}
+	if c := activePermissionCache(); c != nil {
+		return s.cachedCompaniesCanAccessQueue(c, lineage, queueID)
+	}
+	query := database.ConvertPlaceholders(`
+		SELECT EXISTS(
+			SELECT 1 FROM group_customer gc
+			JOIN queue q ON gc.group_id = q.group_id
+			WHERE gc.customer_id IN (` + placeholders(len(lineage)) + `)
+			  AND q.id = ?
+			  AND gc.permission_key IN ('ro', 'rw')
+		)`)
+
+	var hasAccess bool
+	err = s.db.QueryRow(query, append(stringArgs(lineage), queueID)...).Scan(&hasAccess)
+	if err != nil {
+		return false, fmt.Errorf("failed to check customer company tree queue access: %w", err)
+	}
+
+	return hasAccess, nil
+}
+
+// CustomerCompanyDescendants returns the companies below a customer company
+// in the company hierarchy, nearest first.
+func (s *PermissionService) CustomerCompanyDescendants(customerID string) ([]string, error) {
+	var descendants []string
+	seen := map[string]bool{customerID: true}
+	level := []string{customerID}
+	for depth := 1; depth < models.MaxCustomerCompanyDepth && len(level) > 0; depth++ {
+		query := database.ConvertPlaceholders(`
+			SELECT customer_id FROM customer_company
+			WHERE parent_customer_id IN (` + placeholders(len(level)) + `)
+			ORDER BY customer_id`)
+		rows, err := s.db.Query(query, stringArgs(level)...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load subsidiary companies: %w", err)
+		}
+		children, err := database.CollectRows(rows, func(rows *sql.Rows) (string, error) {
+			var id string
+			return id, rows.Scan(&id)
+		})
+		rows.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load subsidiary companies: %w", err)
+		}
+
+		level = level[:0]
+		for _, id := range children {
+			if !seen[id] {
+				seen[id] = true
+				level = append(level, id)
+			}
+		}
+		descendants = append(descendants, level...)
+	}
+	return descendants, nil
+}
+
+// customerCompanyLineage returns a customer company followed by the
+// companies above it, parent first.
+func (s *PermissionService) customerCompanyLineage(customerID string) ([]string, error) {
+	lineage := []string{customerID}
+	seen := map[string]bool{customerID: true}
+	query := database.ConvertPlaceholders(`
+		SELECT COALESCE(parent_customer_id, '') FROM customer_company WHERE customer_id = ?`)
+	for current := customerID; len(lineage) < models.MaxCustomerCompanyDepth; {
+		var parent string
+		err := s.db.QueryRow(query, current).Scan(&parent)
+		if err == sql.ErrNoRows {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to load parent company: %w", err)
+		}
+		if parent == "" || seen[parent] {
+			break
+		}
+		seen[parent] = true
+		lineage = append(lineage, parent)
+		current = parent
+	}
+	return lineage, nil
+}
+
 // CustomerCanAccessTicket checks if a customer can access a specific ticket.
 // Access is granted if:
-// 1. The ticket belongs to the customer's company (customer_id matches), OR
+// 1. The ticket belongs to the customer's company or to a company below it
+// in the company hierarchy, OR
 // 2. The customer user has explicit group access to the ticket's queue, OR
-// 3. The customer's company has explicit group access to the ticket's queue
+// 3. The customer's company, or a company above it, has explicit group
+// access to the ticket's queue
 func (s *PermissionService) CustomerCanAccessTicket(customerLogin, customerCompanyID string, ticketID int64) (bool, error) {
+	owners := []string{customerCompanyID}
+	lineage := []string{customerCompanyID}
+	if customerCompanyID != "" {
+		descendants, err := s.CustomerCompanyDescendants(customerCompanyID)
+		if err != nil {
+			return false, err
+		}
+		owners = append(owners, descendants...)
+		if lineage, err = s.customerCompanyLineage(customerCompanyID); err != nil {
+			return false, err
+		}
+	}
+
 	query := database.ConvertPlaceholders(`
 		SELECT EXISTS(
 			SELECT 1 FROM ticket t
 			WHERE t.id = ?
 			  AND (
-			    -- Company owns the ticket
-			    t.customer_id = ?
+			    -- Company or one of its subsidiaries owns the ticket
+			    t.customer_id IN (` + placeholders(len(owners)) + `)
 			    -- OR customer user has group access to the queue
 			    OR EXISTS(
 			      SELECT 1 FROM group_customer_user gcu
@@ -277,18 +508,22 @@ func (s *PermissionService) CustomerCanAccessTicket(customerLogin, customerCompa
 			      WHERE gcu.user_id = ? AND q.id = t.queue_id
 			        AND gcu.permission_key IN ('ro', 'rw')
 			    )
-			    -- OR customer company has group access to the queue
+			    -- OR customer company or a parent company has group access to the queue
 			    OR EXISTS(
 			      SELECT 1 FROM group_customer gc
 			      JOIN queue q ON gc.group_id = q.group_id
-			      WHERE gc.customer_id = ? AND q.id = t.queue_id
+			      WHERE gc.customer_id IN (` + placeholders(len(lineage)) + `) AND q.id = t.queue_id
 			        AND gc.permission_key IN ('ro', 'rw')
 			    )
 			  )
 		)`)
 
+	args := append([]interface{}{ticketID}, stringArgs(owners)...)
+	args = append(args, customerLogin)
+	args = append(args, stringArgs(lineage)...)
+
 	var hasAccess bool
-	err := s.db.QueryRow(query, ticketID, customerCompanyID, customerLogin, customerCompanyID).Scan(&hasAccess)
+	err := s.db.QueryRow(query, args...).Scan(&hasAccess)
 	if err != nil {
 		return false, fmt.Errorf("failed to check customer ticket access: %w", err)
 	}
@@ -296,6 +531,18 @@ func (s *PermissionService) CustomerCanAccessTicket(customerLogin, customerCompa
 	return hasAccess, nil
 }
 
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
+
+func stringArgs(values []string) []interface{} {
+	args := make([]interface{}, len(values))
+	for i, v := range values {
+		args[i] = v
+	}
+	return args
+}
+
 // =============================================================================
 // GROUP MEMBERSHIP
 // =============================================================================
@@ -306,7 +553,7 @@ func (s *PermissionService) IsInGroup(userID int, groupName string) (bool, error
 	// Try both `groups` (MySQL/MariaDB) and `permission_groups` (Postgres/alternate schema)
 	query := database.ConvertPlaceholders(`
 		SELECT EXISTS(
-			SELECT 1 FROM group_user gu
+			SELECT 1 FROM ` + effectiveGroupUser + ` gu
 			JOIN ` + "`groups`" + ` g ON gu.group_id = g.id
 			WHERE gu.user_id = ?
 			  AND g.name = ?
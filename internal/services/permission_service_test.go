@@ -0,0 +1,156 @@
+package services
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/testutil"
+)
+
+func newCustomerHierarchyPermissionService(t *testing.T) *PermissionService {
+	t.Helper()
+	db := testutil.MigratedDB(t)
+	for _, stmt := range []string{
+		`INSERT INTO users (id, login, pw, first_name, last_name, valid_id, create_time, create_by, change_time, change_by)
+			VALUES (1, 'root@localhost', 'x', 'Admin', 'OTRS', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+		`INSERT INTO groups (id, name, valid_id, create_time, create_by, change_time, change_by) VALUES
+			(10, 'acme', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1),
+			(20, 'acme-eu', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1),
+			(30, 'globex', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+		`UPDATE queue SET group_id = id * 10 WHERE id <= 3`,
+		`INSERT INTO customer_company (customer_id, name, parent_customer_id, valid_id, create_time, create_by, change_time, change_by) VALUES
+			('acme', 'acme', NULL, 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1),
+			('acme-eu', 'acme-eu', 'acme', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1),
+			('acme-de', 'acme-de', 'acme-eu', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1),
+			('globex', 'globex', NULL, 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1),
+			('loop-a', 'loop-a', 'loop-b', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1),
+			('loop-b', 'loop-b', 'loop-a', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+		`INSERT INTO group_customer (customer_id, group_id, permission_key, permission_value, permission_context,
+			create_time, create_by, change_time, change_by) VALUES
+			('acme', 10, 'ro', 1, 'Ticket', CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1),
+			('acme-eu', 20, 'rw', 1, 'Ticket', CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1),
+			('globex', 30, 'rw', 1, 'Ticket', CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+	} {
+		_, err := db.Exec(stmt)
+		require.NoError(t, err, stmt)
+	}
+	for _, tk := range []struct {
+		id, queueID int
+		customerID  string
+	}{{1, 3, "acme-de"}, {2, 3, "acme"}, {3, 1, "globex"}, {4, 3, "globex"}} {
+		_, err := db.Exec(`INSERT INTO ticket (id, tn, title, queue_id, ticket_lock_id, user_id, responsible_user_id,
+			ticket_priority_id, ticket_state_id, customer_id, timeout, until_time, escalation_time, escalation_update_time,
+			escalation_response_time, escalation_solution_time, archive_flag, create_time, create_by, change_time, change_by)
+			VALUES (?, ?, 'Printer', ?, 1, 1, 1, 3, 2, ?, 0, 0, 0, 0, 0, 0, 0, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+			tk.id, fmt.Sprint(1000+tk.id), tk.queueID, tk.customerID)
+		require.NoError(t, err)
+	}
+	return NewPermissionService(db)
+}
+
+func TestCustomerCompanyTreeCanAccessQueue(t *testing.T) {
+	svc := newCustomerHierarchyPermissionService(t)
+
+	tests := []struct {
+		company string
+		queue   int
+		want    bool
+	}{
+		{"acme-de", 1, true},  // granted to acme, two levels up
+		{"acme-de", 2, true},  // granted to acme-eu
+		{"acme", 2, false},    // grants do not flow upwards
+		{"acme-de", 3, false}, // granted to an unrelated company
+		{"loop-a", 1, false},  // cyclic data ends the walk
+	}
+	for _, tt := range tests {
+		got, err := svc.CustomerCompanyTreeCanAccessQueue(tt.company, tt.queue)
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, got, "%s queue %d", tt.company, tt.queue)
+	}
+
+	// The flat check still only looks at the company itself.
+	got, err := svc.CustomerCompanyCanAccessQueue("acme-de", 1)
+	require.NoError(t, err)
+	assert.False(t, got)
+}
+
+func TestCustomerCompanyDescendants(t *testing.T) {
+	svc := newCustomerHierarchyPermissionService(t)
+
+	got, err := svc.CustomerCompanyDescendants("acme")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"acme-eu", "acme-de"}, got)
+
+	got, err = svc.CustomerCompanyDescendants("loop-a")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"loop-b"}, got)
+}
+
+func TestCustomerCanAccessTicketInheritsDownTheHierarchy(t *testing.T) {
+	svc := newCustomerHierarchyPermissionService(t)
+
+	tests := []struct {
+		company string
+		ticket  int64
+		want    bool
+	}{
+		{"acme", 1, true},     // a subsidiary's ticket
+		{"acme-de", 2, false}, // the parent company's ticket
+		{"acme-de", 3, true},  // queue 1 is granted to acme
+		{"acme", 4, false},    // another company's ticket in another company's queue
+		{"", 2, false},
+	}
+	for _, tt := range tests {
+		got, err := svc.CustomerCanAccessTicket("someone@example.com", tt.company, tt.ticket)
+		require.NoError(t, err)
+		assert.Equal(t, tt.want, got, "%q ticket %d", tt.company, tt.ticket)
+	}
+}
+
+func TestPermissionService_RolesAddToDirectPermissions(t *testing.T) {
+	db := testutil.MigratedDB(t)
+	// Queues 1 and 2 belong to the seeded users and admin groups, queue 3 to
+	// billing; queue 4 is moved out of the way to stats.
+	for _, stmt := range []string{
+		`INSERT INTO users (id, login, pw, first_name, last_name, valid_id, create_time, create_by, change_time, change_by)
+			VALUES (1, 'root@localhost', 'x', 'Admin', 'OTRS', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+		`INSERT INTO groups (id, name, valid_id, create_time, create_by, change_time, change_by)
+			VALUES (30, 'billing', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+		`UPDATE queue SET group_id = CASE id WHEN 1 THEN 1 WHEN 2 THEN 2 WHEN 3 THEN 30 ELSE 3 END`,
+		`INSERT INTO group_user (user_id, group_id, permission_key, create_time, create_by, change_time, change_by)
+			VALUES (1, 1, 'ro', CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+		`INSERT INTO roles (id, name, valid_id, create_time, create_by, change_time, change_by) VALUES
+			(1, 'admins', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1),
+			(2, 'billing', 2, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+		`INSERT INTO role_user (user_id, role_id, create_time, create_by, change_time, change_by) VALUES
+			(1, 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1),
+			(1, 2, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+		`INSERT INTO group_role (role_id, group_id, permission_key, permission_value, create_time, create_by, change_time, change_by) VALUES
+			(1, 1, 'rw', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1),
+			(1, 2, 'rw', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1),
+			(1, 30, 'note', 0, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1),
+			(2, 30, 'rw', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1)`,
+	} {
+		_, err := db.Exec(stmt)
+		require.NoError(t, err, stmt)
+	}
+	svc := NewPermissionService(db)
+
+	perms, err := svc.GetUserQueuePermissions(1)
+	require.NoError(t, err)
+	assert.Equal(t, map[int]string{1: "rw", 2: "rw"}, perms, "rw from the role beats the direct ro")
+
+	ok, err := svc.HasPermission(1, 3, "note")
+	require.NoError(t, err)
+	assert.False(t, ok, "permission_value 0 grants nothing")
+	ok, err = svc.CanReadQueue(1, 3)
+	require.NoError(t, err)
+	assert.False(t, ok, "invalid roles grant nothing")
+
+	ok, err = svc.IsInGroup(1, "admin")
+	require.NoError(t, err)
+	assert.True(t, ok, "role membership counts for group checks")
+}
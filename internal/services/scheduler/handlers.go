@@ -6,6 +6,7 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"os"
 	"strconv"
 	"strings"
 	"sync"
@@ -16,6 +17,7 @@ import (
 	"github.com/goatkit/goatflow/internal/email/inbound/connector"
 	"github.com/goatkit/goatflow/internal/models"
 	"github.com/goatkit/goatflow/internal/notifications"
+	"github.com/goatkit/goatflow/internal/service"
 	"github.com/goatkit/goatflow/internal/services/escalation"
 	"github.com/goatkit/goatflow/internal/services/genericagent"
 )
@@ -28,6 +30,12 @@ func (s *Service) registerBuiltinHandlers() {
 	s.RegisterHandler("genericAgent.execute", s.handleGenericAgentExecute)
 	s.RegisterHandler("escalation.check", s.handleEscalationCheck)
 	s.RegisterHandler("metrics.ticketActivity", s.handleMetricsTicketActivity)
+	s.RegisterHandler("ticket.archive", s.handleTicketArchive)
+	s.RegisterHandler("survey.dispatch", s.handleSurveyDispatch)
+	s.RegisterHandler("stats.queueAggregate", s.handleQueueStatsAggregate)
+	s.RegisterHandler("telemetry.submit", s.handleTelemetrySubmit)
+	s.RegisterHandler("backup.create", s.handleBackup)
+	s.RegisterHandler("plugin.logRetention", s.handlePluginLogRetention)
 }
 
 func (s *Service) handleAutoClose(ctx context.Context, job *models.ScheduledJob) error {
@@ -332,6 +340,128 @@ func (s *Service) handleMetricsTicketActivity(ctx context.Context, job *models.S
 	return nil
 }
 
+// handleTicketArchive applies the archive policies: it archives closed
+// tickets that have not changed for long enough and purges archived ones
+// past their retention.
+func (s *Service) handleTicketArchive(ctx context.Context, job *models.ScheduledJob) error {
+	if s.db == nil {
+		s.logger.Printf("scheduler: database unavailable, skipping ticket archive")
+		return nil
+	}
+
+	svc := service.NewTicketArchiveService(s.db)
+	svc.SetBatchSize(intFromConfig(job.Config, "batch_size", 500))
+	if s.ticketIndexer != nil {
+		svc.SetIndexer(s.ticketIndexer)
+	}
+	run, err := svc.Run(ctx, nil, false, intFromConfig(job.Config, "system_user_id", 1))
+	if err != nil {
+		return err
+	}
+	if run.TicketsArchived > 0 || run.TicketsPurged > 0 {
+		s.logger.Printf("scheduler: ticket archive run %d archived %d ticket(s), moved %d article(s), purged %d ticket(s)",
+			run.ID, run.TicketsArchived, run.ArticlesMoved, run.TicketsPurged)
+	}
+	return nil
+}
+
+// handleSurveyDispatch emails the satisfaction surveys for tickets closed
+// since the last run.
+func (s *Service) handleSurveyDispatch(ctx context.Context, job *models.ScheduledJob) error {
+	if s.db == nil {
+		s.logger.Printf("scheduler: database unavailable, skipping survey dispatch")
+		return nil
+	}
+
+	sent, err := service.NewSurveyService(s.db).Dispatch(ctx, intFromConfig(job.Config, "limit", 100))
+	if sent > 0 {
+		s.logger.Printf("scheduler: sent %d satisfaction survey(s)", sent)
+	}
+	return err
+}
+
+// handleQueueStatsAggregate computes the daily queue statistics of the
+// completed days that have none yet.
+func (s *Service) handleQueueStatsAggregate(ctx context.Context, job *models.ScheduledJob) error {
+	if s.db == nil {
+		s.logger.Printf("scheduler: database unavailable, skipping queue statistics")
+		return nil
+	}
+
+	svc := service.NewQueueStatsService(s.db)
+	svc.SetLocation(s.location)
+	days, err := svc.Aggregate(ctx, intFromConfig(job.Config, "backfill_days", 7), intFromConfig(job.Config, "retention_days", 730))
+	if days > 0 {
+		s.logger.Printf("scheduler: aggregated queue statistics for %d day(s)", days)
+	}
+	return err
+}
+
+// handleTelemetrySubmit removes telemetry usage past its retention and, when
+// the admin opted in to submitting, sends yesterday's anonymous report.
+func (s *Service) handleTelemetrySubmit(ctx context.Context, job *models.ScheduledJob) error {
+	if s.db == nil {
+		s.logger.Printf("scheduler: database unavailable, skipping telemetry")
+		return nil
+	}
+
+	svc := service.NewTelemetryService(s.db)
+	if _, err := svc.Prune(ctx, intFromConfig(job.Config, "retention_days", 90)); err != nil {
+		return err
+	}
+	report, err := svc.Submit(ctx)
+	if errors.Is(err, service.ErrTelemetrySubmitDisabled) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	s.logger.Printf("scheduler: submitted telemetry report for %s", report.Date)
+	return nil
+}
+
+// handlePluginLogRetention removes stored plugin log entries older than the
+// retention of their plugin.
+func (s *Service) handlePluginLogRetention(ctx context.Context, job *models.ScheduledJob) error {
+	if s.db == nil {
+		s.logger.Printf("scheduler: database unavailable, skipping plugin log retention")
+		return nil
+	}
+
+	removed, err := service.NewPluginLogService(s.db).Prune(ctx,
+		intFromConfig(job.Config, "retention_days", 14), intMapFromConfig(job.Config, "plugins"))
+	if removed > 0 {
+		s.logger.Printf("scheduler: removed %d plugin log entries", removed)
+	}
+	return err
+}
+
+// handleBackup queues a backup of the installation. Nothing is backed up
+// until a directory is configured, in the job or in BACKUP_DIR.
+func (s *Service) handleBackup(ctx context.Context, job *models.ScheduledJob) error {
+	if s.db == nil {
+		s.logger.Printf("scheduler: database unavailable, skipping backup")
+		return nil
+	}
+	dir := stringFromConfig(job.Config, "dir", os.Getenv("BACKUP_DIR"))
+	if dir == "" {
+		return nil
+	}
+
+	jobID, err := service.NewBackupService(s.db).Schedule(ctx, service.BackupJob{
+		Dir:           dir,
+		FullEveryDays: intFromConfig(job.Config, "full_every_days", 7),
+		RetentionDays: intFromConfig(job.Config, "retention_days", 30),
+	})
+	if err != nil {
+		return err
+	}
+	if jobID != "" {
+		s.logger.Printf("scheduler: queued backup job %s", jobID)
+	}
+	return nil
+}
+
 // calculateTicketActivityMetrics computes ticket counts for dashboard display.
 func calculateTicketActivityMetrics(db *sql.DB) map[string]int {
 	metrics := make(map[string]int)
@@ -464,6 +594,72 @@ func defaultJobs() []*models.ScheduledJob {
 			RunOnStartup:   true, // Populate cache immediately so dashboard has data
 			Config:         map[string]any{},
 		},
+		{
+			Name:           "Ticket Archiving",
+			Slug:           "ticket-archive",
+			Handler:        "ticket.archive",
+			Schedule:       "30 2 * * *",
+			TimeoutSeconds: 1800,
+			Config: map[string]any{
+				"batch_size":     500, // tickets per policy and run
+				"system_user_id": 1,
+			},
+		},
+		{
+			Name:           "Satisfaction Surveys",
+			Slug:           "survey-dispatch",
+			Handler:        "survey.dispatch",
+			Schedule:       "*/5 * * * *",
+			TimeoutSeconds: 300,
+			Config: map[string]any{
+				"limit": 100, // surveys sent per run
+			},
+		},
+		{
+			Name:           "Queue Statistics",
+			Slug:           "queue-stats",
+			Handler:        "stats.queueAggregate",
+			Schedule:       "15 1 * * *",
+			TimeoutSeconds: 1800,
+			RunOnStartup:   true, // Fill in days missed while the service was down
+			Config: map[string]any{
+				"backfill_days":  7,   // missed days filled in per run
+				"retention_days": 730, // 0 keeps statistics forever
+			},
+		},
+		{
+			Name:           "Telemetry",
+			Slug:           "telemetry",
+			Handler:        "telemetry.submit",
+			Schedule:       "40 2 * * *",
+			TimeoutSeconds: 300,
+			Config: map[string]any{
+				"retention_days": 90, // days of usage kept for System Insights
+			},
+		},
+		{
+			Name:           "Backup",
+			Slug:           "backup",
+			Handler:        "backup.create",
+			Schedule:       "0 1 * * *",
+			TimeoutSeconds: 60, // Only queues the backup; the job queue runs it
+			Config: map[string]any{
+				"dir":             "", // empty uses BACKUP_DIR; no backups without either
+				"full_every_days": 7,  // incremental backups in between
+				"retention_days":  30, // 0 keeps all archives
+			},
+		},
+		{
+			Name:           "Plugin Log Retention",
+			Slug:           "plugin-log-retention",
+			Handler:        "plugin.logRetention",
+			Schedule:       "50 2 * * *",
+			TimeoutSeconds: 600,
+			Config: map[string]any{
+				"retention_days": 14,               // 0 keeps entries forever
+				"plugins":        map[string]any{}, // per-plugin days, e.g. {"stats": 30}
+			},
+		},
 	}
 }
 
@@ -499,12 +695,21 @@ func (s *Service) handlePendingReminder(ctx context.Context, job *models.Schedul
 		return nil
 	}
 
+	// Reminders for agents who are out of office go to their substitutes.
+	var outOfOffice *service.OutOfOfficeService
+	if s.db != nil {
+		outOfOffice = service.NewOutOfOfficeService(s.db)
+	}
+
 	dispatched := 0
 	for _, reminder := range reminders {
 		recipients := recipientsForReminder(reminder)
 		if len(recipients) == 0 {
 			continue
 		}
+		if outOfOffice != nil {
+			recipients = outOfOffice.SubstituteRecipients(ctx, recipients)
+		}
 		payload := convertReminder(reminder)
 		if err := s.reminderHub.Dispatch(ctx, recipients, payload); err != nil {
 			s.logger.Printf("scheduler: failed to dispatch pending reminder for ticket %s: %v", reminder.TicketNumber, err)
@@ -574,6 +779,32 @@ func intFromConfig(cfg map[string]any, key string, def int) int {
 	return def
 }
 
+func stringFromConfig(cfg map[string]any, key, def string) string {
+	if cfg == nil {
+		return def
+	}
+	if v, ok := cfg[key].(string); ok && strings.TrimSpace(v) != "" {
+		return strings.TrimSpace(v)
+	}
+	return def
+}
+
+// intMapFromConfig reads a map of names to integers, such as per-plugin
+// retention days.
+func intMapFromConfig(cfg map[string]any, key string) map[string]int {
+	result := make(map[string]int)
+	raw, ok := cfg[key].(map[string]any)
+	if !ok {
+		return result
+	}
+	for name := range raw {
+		if n := strings.TrimSpace(name); n != "" {
+			result[n] = intFromConfig(raw, name, 0)
+		}
+	}
+	return result
+}
+
 func transitionsFromConfig(cfg map[string]any) map[string]string {
 	result := make(map[string]string)
 	if cfg == nil {
@@ -10,6 +10,7 @@ import (
 	"github.com/goatkit/goatflow/internal/email/inbound/connector"
 	"github.com/goatkit/goatflow/internal/models"
 	"github.com/goatkit/goatflow/internal/notifications"
+	"github.com/goatkit/goatflow/internal/service"
 )
 
 type options struct {
@@ -24,6 +25,7 @@ type options struct {
 	Location     *time.Location
 	ReminderHub  notifications.Hub
 	Cache        *cache.RedisCache
+	Indexer      service.TicketIndexer
 }
 
 // Option applies configuration to the scheduler service.
@@ -103,6 +105,14 @@ func WithReminderHub(h notifications.Hub) Option {
 	}
 }
 
+// WithTicketIndexer sets the search indexer told about tickets the
+// archive job archives.
+func WithTicketIndexer(indexer service.TicketIndexer) Option {
+	return func(o *options) {
+		o.Indexer = indexer
+	}
+}
+
 // WithCache injects the Redis/Valkey cache client used for status persistence.
 func WithCache(c *cache.RedisCache) Option {
 	return func(o *options) {
@@ -15,6 +15,7 @@ import (
 	"github.com/goatkit/goatflow/internal/models"
 	"github.com/goatkit/goatflow/internal/notifications"
 	"github.com/goatkit/goatflow/internal/repository"
+	"github.com/goatkit/goatflow/internal/service"
 )
 
 const (
@@ -57,6 +58,7 @@ type Service struct {
 	emailPollState   emailPollState
 	metrics          *emailPollMetrics
 	valkey           *cache.RedisCache
+	ticketIndexer    service.TicketIndexer
 }
 
 type emailPollState struct {
@@ -136,6 +138,7 @@ func NewService(db *sql.DB, opts ...Option) *Service {
 		reminderHub:      hub,
 		metrics:          globalEmailPollMetrics(),
 		valkey:           options.Cache,
+		ticketIndexer:    options.Indexer,
 	}
 
 	// The following line initializes emailPollState with nextIdx set to 0
@@ -6,9 +6,7 @@ import (
 	"context"
 	"database/sql"
 	"encoding/base64"
-	"encoding/csv"
 	"fmt"
-	"io"
 	"log"
 	"sort"
 	"strings"
@@ -16,7 +14,7 @@ import (
 
 	"github.com/goatkit/goatflow/internal/database"
 	"github.com/goatkit/goatflow/internal/models"
-	"github.com/xuri/excelize/v2"
+	"github.com/goatkit/goatflow/internal/spreadsheet"
 )
 
 // Service manages ticket attribute relations.
@@ -373,52 +371,19 @@ func (s *Service) parseACLData(filename, data, attr1, attr2 string) ([]models.At
 
 // parseCSVData parses CSV content.
 func (s *Service) parseCSVData(data, attr1, attr2 string) ([]models.AttributeRelationPair, error) {
-	reader := csv.NewReader(strings.NewReader(data))
-	reader.Comma = ';' // OTRS uses semicolon as separator
-
-	// Read header row
-	header, err := reader.Read()
+	rows, err := spreadsheet.ReadCSV([]byte(data), ';') // OTRS uses semicolon as separator
 	if err != nil {
-		return nil, fmt.Errorf("read CSV header: %w", err)
+		return nil, err
 	}
-
-	if len(header) != 2 {
-		return nil, fmt.Errorf("CSV must have exactly 2 columns, got %d", len(header))
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("read CSV header: file is empty")
 	}
 
-	// Read data rows
-	var pairs []models.AttributeRelationPair
-	for {
-		record, err := reader.Read()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			return nil, fmt.Errorf("read CSV row: %w", err)
-		}
-
-		if len(record) < 2 {
-			continue
-		}
-
-		val1 := strings.TrimSpace(record[0])
-		val2 := strings.TrimSpace(record[1])
-
-		// Handle "-" as empty value
-		if val1 == "-" {
-			val1 = ""
-		}
-		if val2 == "-" {
-			val2 = ""
-		}
-
-		pairs = append(pairs, models.AttributeRelationPair{
-			Attribute1Value: val1,
-			Attribute2Value: val2,
-		})
+	if len(rows[0]) != 2 {
+		return nil, fmt.Errorf("CSV must have exactly 2 columns, got %d", len(rows[0]))
 	}
 
-	return pairs, nil
+	return relationPairs(rows[1:]), nil
 }
 
 // parseExcelData parses base64-encoded Excel data.
@@ -429,22 +394,9 @@ func (s *Service) parseExcelData(data, attr1, attr2 string) ([]models.AttributeR
 		return nil, fmt.Errorf("decode base64: %w", err)
 	}
 
-	// Open Excel file
-	f, err := excelize.OpenReader(strings.NewReader(string(decoded)))
+	rows, err := spreadsheet.ReadExcel(decoded)
 	if err != nil {
-		return nil, fmt.Errorf("open Excel: %w", err)
-	}
-	defer f.Close()
-
-	// Get first sheet
-	sheets := f.GetSheetList()
-	if len(sheets) == 0 {
-		return nil, fmt.Errorf("no sheets in Excel file")
-	}
-
-	rows, err := f.GetRows(sheets[0])
-	if err != nil {
-		return nil, fmt.Errorf("get Excel rows: %w", err)
+		return nil, err
 	}
 
 	if len(rows) < 2 {
@@ -457,31 +409,7 @@ func (s *Service) parseExcelData(data, attr1, attr2 string) ([]models.AttributeR
 		return nil, fmt.Errorf("Excel must have at least 2 columns, got %d", len(header))
 	}
 
-	// Parse data rows (skip header)
-	var pairs []models.AttributeRelationPair
-	for _, row := range rows[1:] {
-		if len(row) < 2 {
-			continue
-		}
-
-		val1 := strings.TrimSpace(row[0])
-		val2 := strings.TrimSpace(row[1])
-
-		// Handle "-" as empty value
-		if val1 == "-" {
-			val1 = ""
-		}
-		if val2 == "-" {
-			val2 = ""
-		}
-
-		pairs = append(pairs, models.AttributeRelationPair{
-			Attribute1Value: val1,
-			Attribute2Value: val2,
-		})
-	}
-
-	return pairs, nil
+	return relationPairs(rows[1:]), nil
 }
 
 // ParseUploadedFile parses an uploaded CSV or Excel file and returns the relation data.
@@ -494,79 +422,31 @@ func (s *Service) ParseUploadedFile(filename string, data []byte) (attr1, attr2
 
 // parseCSVUpload parses an uploaded CSV file.
 func (s *Service) parseCSVUpload(data []byte) (attr1, attr2 string, pairs []models.AttributeRelationPair, err error) {
-	reader := csv.NewReader(strings.NewReader(string(data)))
-	reader.Comma = ';'
-
-	// Read header row (contains attribute names)
-	header, err := reader.Read()
+	rows, err := spreadsheet.ReadCSV(data, ';')
 	if err != nil {
-		return "", "", nil, fmt.Errorf("read CSV header: %w", err)
+		return "", "", nil, err
 	}
-
-	if len(header) != 2 {
-		return "", "", nil, fmt.Errorf("CSV must have exactly 2 columns, got %d", len(header))
+	if len(rows) == 0 {
+		return "", "", nil, fmt.Errorf("read CSV header: file is empty")
 	}
 
-	attr1 = strings.TrimSpace(header[0])
-	attr2 = strings.TrimSpace(header[1])
-
-	// Validate attribute names
-	if !models.IsValidAttribute(attr1) {
-		return "", "", nil, fmt.Errorf("invalid attribute: %s", attr1)
+	// Header row contains attribute names
+	if len(rows[0]) != 2 {
+		return "", "", nil, fmt.Errorf("CSV must have exactly 2 columns, got %d", len(rows[0]))
 	}
-	if !models.IsValidAttribute(attr2) {
-		return "", "", nil, fmt.Errorf("invalid attribute: %s", attr2)
-	}
-
-	// Read data rows
-	for {
-		record, err := reader.Read()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			return "", "", nil, fmt.Errorf("read CSV row: %w", err)
-		}
-
-		if len(record) < 2 {
-			continue
-		}
-
-		val1 := strings.TrimSpace(record[0])
-		val2 := strings.TrimSpace(record[1])
-
-		if val1 == "-" {
-			val1 = ""
-		}
-		if val2 == "-" {
-			val2 = ""
-		}
 
-		pairs = append(pairs, models.AttributeRelationPair{
-			Attribute1Value: val1,
-			Attribute2Value: val2,
-		})
+	attr1, attr2, err = relationAttributes(rows[0])
+	if err != nil {
+		return "", "", nil, err
 	}
-
-	return attr1, attr2, pairs, nil
+	return attr1, attr2, relationPairs(rows[1:]), nil
 }
 
 // parseExcelUpload parses an uploaded Excel file.
 func (s *Service) parseExcelUpload(data []byte) (attr1, attr2 string, pairs []models.AttributeRelationPair, err error) {
-	f, err := excelize.OpenReader(strings.NewReader(string(data)))
+	rows, err := spreadsheet.ReadExcel(data)
 	if err != nil {
-		return "", "", nil, fmt.Errorf("open Excel: %w", err)
-	}
-	defer f.Close()
-
-	sheets := f.GetSheetList()
-	if len(sheets) == 0 {
-		return "", "", nil, fmt.Errorf("no sheets in Excel file")
-	}
-
-	rows, err := f.GetRows(sheets[0])
-	if err != nil {
-		return "", "", nil, fmt.Errorf("get Excel rows: %w", err)
+		return "", "", nil, err
 	}
 
 	if len(rows) < 1 {
@@ -574,24 +454,36 @@ func (s *Service) parseExcelUpload(data []byte) (attr1, attr2 string, pairs []mo
 	}
 
 	// Read header (attribute names)
-	header := rows[0]
-	if len(header) < 2 {
-		return "", "", nil, fmt.Errorf("Excel must have at least 2 columns, got %d", len(header))
+	if len(rows[0]) < 2 {
+		return "", "", nil, fmt.Errorf("Excel must have at least 2 columns, got %d", len(rows[0]))
 	}
 
+	attr1, attr2, err = relationAttributes(rows[0])
+	if err != nil {
+		return "", "", nil, err
+	}
+	return attr1, attr2, relationPairs(rows[1:]), nil
+}
+
+// relationAttributes validates the attribute names in an uploaded header row.
+func relationAttributes(header []string) (attr1, attr2 string, err error) {
 	attr1 = strings.TrimSpace(header[0])
 	attr2 = strings.TrimSpace(header[1])
 
-	// Validate attribute names
 	if !models.IsValidAttribute(attr1) {
-		return "", "", nil, fmt.Errorf("invalid attribute: %s", attr1)
+		return "", "", fmt.Errorf("invalid attribute: %s", attr1)
 	}
 	if !models.IsValidAttribute(attr2) {
-		return "", "", nil, fmt.Errorf("invalid attribute: %s", attr2)
+		return "", "", fmt.Errorf("invalid attribute: %s", attr2)
 	}
+	return attr1, attr2, nil
+}
 
-	// Parse data rows (skip header)
-	for _, row := range rows[1:] {
+// relationPairs converts data rows to value pairs, skipping rows with fewer
+// than two cells. "-" stands for an empty value.
+func relationPairs(rows [][]string) []models.AttributeRelationPair {
+	var pairs []models.AttributeRelationPair
+	for _, row := range rows {
 		if len(row) < 2 {
 			continue
 		}
@@ -611,8 +503,7 @@ func (s *Service) parseExcelUpload(data []byte) (attr1, attr2 string, pairs []mo
 			Attribute2Value: val2,
 		})
 	}
-
-	return attr1, attr2, pairs, nil
+	return pairs
 }
 
 // PrepareDataForStorage prepares file data for database storage.
@@ -684,8 +575,7 @@ func (s *Service) GetPriorityOptions(ctx context.Context) ([]int64, error) {
 
 // isExcelFilename checks if a filename has an Excel extension.
 func isExcelFilename(filename string) bool {
-	lower := strings.ToLower(filename)
-	return strings.HasSuffix(lower, ".xlsx") || strings.HasSuffix(lower, ".xls")
+	return spreadsheet.IsExcelFilename(filename)
 }
 
 // intersectSlices returns the intersection of two string slices.
@@ -8,6 +8,7 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/flosch/pongo2/v6"
 	"github.com/gin-gonic/gin"
@@ -19,6 +20,7 @@ import (
 	"github.com/goatkit/goatflow/internal/middleware"
 	"github.com/goatkit/goatflow/internal/models"
 	"github.com/goatkit/goatflow/internal/repository"
+	"github.com/goatkit/goatflow/internal/service"
 	"github.com/goatkit/goatflow/internal/version"
 )
 
@@ -29,11 +31,20 @@ type TemplateOverrideProvider interface {
 
 var globalTemplateOverrideProvider TemplateOverrideProvider
 
+// templateHotReload subscribes agent pages to the event stream so they reload
+// when a plugin changes the override of the template they were rendered from.
+var templateHotReload bool
+
 // SetTemplateOverrideProvider sets the global template override provider.
 func SetTemplateOverrideProvider(p TemplateOverrideProvider) {
 	globalTemplateOverrideProvider = p
 }
 
+// SetTemplateHotReload enables reloading open pages on plugin template changes.
+func SetTemplateHotReload(enabled bool) {
+	templateHotReload = enabled
+}
+
 // TemplateRenderer handles template rendering with pongo2.
 type TemplateRenderer struct {
 	templateSet *pongo2.TemplateSet
@@ -92,6 +103,9 @@ func (r *TemplateRenderer) HTML(c *gin.Context, code int, name string, data inte
 	ctx["t"] = func(key string, args ...interface{}) string {
 		return translateWithFallback(i18nInst, lang, key, args...)
 	}
+	ctx["tn"] = func(key string, count int, args ...interface{}) string {
+		return i18nInst.Plural(lang, key, count, args...)
+	}
 	ctx["getLang"] = func() string { return lang }
 	ctx["getDirection"] = func() string { return string(i18n.GetDirection(lang)) }
 	ctx["isRTL"] = func() bool { return i18n.IsRTL(lang) }
@@ -124,9 +138,21 @@ func (r *TemplateRenderer) HTML(c *gin.Context, code int, name string, data inte
 		}
 	}
 
+	// Forms and the layouts' HTMX/fetch hook send this back on POSTs
+	ctx["CSRFToken"] = middleware.CSRFToken(c)
+
 	// Check for active/upcoming maintenance and add to context
 	addMaintenanceContext(ctx)
 
+	// Add the branding of the portal domain's tenant, or the global one
+	addBrandingContext(c, ctx)
+
+	// Let agent pages reload themselves when a plugin changes this template;
+	// the event stream is not open to customers
+	_, signedIn := c.Get("user_id")
+	ctx["TemplateName"] = name
+	ctx["TemplateHotReload"] = templateHotReload && signedIn && !c.GetBool("is_customer")
+
 	// Check for plugin template override
 	if globalTemplateOverrideProvider != nil {
 		// Convert pongo2.Context to map for plugin
@@ -274,4 +300,35 @@ func addMaintenanceContext(ctx pongo2.Context) {
 	if coming, err := repo.IsComing(upcomingMinutes); err == nil && coming != nil {
 		ctx["MaintenanceComing"] = coming
 	}
+
+	// Announcements; the layout shows those meant for the agent or the
+	// customer interface
+	if announcements, err := repository.NewSystemAnnouncementRepository(db).ListActive(context.Background(), time.Now()); err == nil && len(announcements) > 0 {
+		ctx["SystemAnnouncements"] = announcements
+	}
+}
+
+// addBrandingContext adds the effective branding to the template context:
+// that of the customer company owning the request's portal domain, else the
+// global one.
+func addBrandingContext(c *gin.Context, ctx pongo2.Context) {
+	if _, ok := ctx["Branding"]; ok {
+		return
+	}
+	db, err := database.GetDB()
+	if err != nil || db == nil {
+		return
+	}
+	tenant := ""
+	if v, ok := c.Get("portal_domain"); ok {
+		if d, _ := v.(*models.PortalDomain); d != nil {
+			tenant = d.CustomerID
+		}
+	}
+	branding, err := service.NewBrandingService(db).Branding(c.Request.Context(), tenant)
+	if err != nil {
+		log.Printf("branding for %q unavailable: %v", tenant, err)
+		return
+	}
+	ctx["Branding"] = branding
 }
@@ -0,0 +1,84 @@
+// Package spreadsheet reads the rows of uploaded CSV and Excel files.
+// This package has no dependencies on other internal packages to avoid circular imports.
+package spreadsheet
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// utf8BOM is written at the start of CSV files saved by Excel.
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// IsExcelFilename checks if a filename has an Excel extension.
+func IsExcelFilename(filename string) bool {
+	lower := strings.ToLower(filename)
+	return strings.HasSuffix(lower, ".xlsx") || strings.HasSuffix(lower, ".xls")
+}
+
+// Read returns the rows of a CSV or Excel file, told apart by filename.
+// CSV files use comma as separator; pass 0 to detect it from the first line.
+func Read(filename string, data []byte, comma rune) ([][]string, error) {
+	if IsExcelFilename(filename) {
+		return ReadExcel(data)
+	}
+	return ReadCSV(data, comma)
+}
+
+// ReadCSV returns the rows of CSV data. Every row must have as many fields
+// as the first. A comma of 0 picks whichever of ';', ',' and tab occurs most
+// in the first line.
+func ReadCSV(data []byte, comma rune) ([][]string, error) {
+	data = bytes.TrimPrefix(data, utf8BOM)
+	if comma == 0 {
+		comma = DetectSeparator(data)
+	}
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.Comma = comma
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("read CSV: %w", err)
+	}
+	return rows, nil
+}
+
+// DetectSeparator guesses the separator of CSV data from its first line,
+// preferring ';' (the OTRS default) on a tie.
+func DetectSeparator(data []byte) rune {
+	line := data
+	if i := bytes.IndexByte(data, '\n'); i >= 0 {
+		line = data[:i]
+	}
+	best, bestCount := ';', bytes.Count(line, []byte{';'})
+	for _, sep := range []rune{',', '\t'} {
+		if n := bytes.Count(line, []byte(string(sep))); n > bestCount {
+			best, bestCount = sep, n
+		}
+	}
+	return best
+}
+
+// ReadExcel returns the rows of the first sheet of an Excel workbook.
+// Trailing empty cells are left out, so rows can be shorter than the header.
+func ReadExcel(data []byte) ([][]string, error) {
+	f, err := excelize.OpenReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("open Excel: %w", err)
+	}
+	defer f.Close()
+
+	sheets := f.GetSheetList()
+	if len(sheets) == 0 {
+		return nil, fmt.Errorf("no sheets in Excel file")
+	}
+
+	rows, err := f.GetRows(sheets[0])
+	if err != nil {
+		return nil, fmt.Errorf("get Excel rows: %w", err)
+	}
+	return rows, nil
+}
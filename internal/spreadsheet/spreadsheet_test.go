@@ -0,0 +1,63 @@
+package spreadsheet
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xuri/excelize/v2"
+)
+
+func TestIsExcelFilename(t *testing.T) {
+	assert.True(t, IsExcelFilename("users.XLSX"))
+	assert.True(t, IsExcelFilename("users.xls"))
+	assert.False(t, IsExcelFilename("users.csv"))
+	assert.False(t, IsExcelFilename("xlsx"))
+}
+
+func TestReadCSV(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		comma   rune
+		want    [][]string
+		wantErr bool
+	}{
+		{"semicolon", "login;email\njdoe;j@example.com", 0, [][]string{{"login", "email"}, {"jdoe", "j@example.com"}}, false},
+		{"comma", "login,email\njdoe,j@example.com", 0, [][]string{{"login", "email"}, {"jdoe", "j@example.com"}}, false},
+		{"tab", "login\temail\njdoe\tj@example.com", 0, [][]string{{"login", "email"}, {"jdoe", "j@example.com"}}, false},
+		{"byte order mark", "\xEF\xBB\xBFlogin;email\n", 0, [][]string{{"login", "email"}}, false},
+		{"explicit separator", "a,b;c\n", ';', [][]string{{"a,b", "c"}}, false},
+		{"quoted separator", "name;city\n\"Doe; Jane\";Berlin", 0, [][]string{{"name", "city"}, {"Doe; Jane", "Berlin"}}, false},
+		{"ragged rows", "a;b\n1;2;3", 0, nil, true},
+		{"empty", "", 0, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, err := ReadCSV([]byte(tt.data), tt.comma)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, rows)
+		})
+	}
+}
+
+func TestRead_Excel(t *testing.T) {
+	f := excelize.NewFile()
+	sheet := f.GetSheetName(0)
+	require.NoError(t, f.SetSheetRow(sheet, "A1", &[]interface{}{"login", "email"}))
+	require.NoError(t, f.SetSheetRow(sheet, "A2", &[]interface{}{"jdoe", "j@example.com"}))
+	var buf bytes.Buffer
+	require.NoError(t, f.Write(&buf))
+
+	rows, err := Read("users.xlsx", buf.Bytes(), 0)
+	require.NoError(t, err)
+	assert.Equal(t, [][]string{{"login", "email"}, {"jdoe", "j@example.com"}}, rows)
+
+	_, err = Read("users.xlsx", []byte("not a workbook"), 0)
+	assert.Error(t, err)
+}
@@ -0,0 +1,266 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compressed values carry their codec in a short prefix, so readers never
+// need the compression settings and rows written before compression was
+// enabled (or by OTRS) pass through unchanged.
+//
+// Text columns (article_data_mime.a_body) hold "gfz1:<codec>:" followed by
+// the base64 payload, which keeps them valid UTF-8 for PostgreSQL TEXT.
+// Binary columns (article_data_mime_attachment.content) hold "\x00GFZ",
+// one byte with the codec name length, the codec name and the raw payload.
+const (
+	textEnvelope   = "gfz1:"
+	binaryEnvelope = "\x00GFZ"
+)
+
+// Codec compresses and decompresses stored content.
+type Codec interface {
+	// Name identifies the codec in stored envelopes and configuration.
+	Name() string
+	// Compress compresses data at the given level (0 = codec default).
+	Compress(data []byte, level int) ([]byte, error)
+	// Decompress reverses Compress.
+	Decompress(data []byte) ([]byte, error)
+}
+
+var (
+	codecsMu sync.RWMutex
+	codecs   = map[string]Codec{}
+)
+
+// RegisterCodec makes a codec available by name. Registering a name twice
+// replaces the earlier codec.
+func RegisterCodec(c Codec) {
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+	codecs[c.Name()] = c
+}
+
+// GetCodec returns the codec registered under name.
+func GetCodec(name string) (Codec, error) {
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+	c, ok := codecs[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown compression codec: %s", name)
+	}
+	return c, nil
+}
+
+func init() {
+	RegisterCodec(&zstdCodec{})
+}
+
+// zstdCodec compresses with Zstandard. Levels follow the zstd command line
+// (1 fastest to 19 smallest) and are mapped onto the encoder's presets.
+type zstdCodec struct {
+	decoderOnce sync.Once
+	decoder     *zstd.Decoder
+	decoderErr  error
+}
+
+func (z *zstdCodec) Name() string { return "zstd" }
+
+func (z *zstdCodec) Compress(data []byte, level int) ([]byte, error) {
+	opts := []zstd.EOption{zstd.WithEncoderConcurrency(1)}
+	if level > 0 {
+		opts = append(opts, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)))
+	}
+	enc, err := zstd.NewWriter(nil, opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer enc.Close()
+	return enc.EncodeAll(data, make([]byte, 0, len(data)/2)), nil
+}
+
+func (z *zstdCodec) Decompress(data []byte) ([]byte, error) {
+	z.decoderOnce.Do(func() {
+		z.decoder, z.decoderErr = zstd.NewReader(nil, zstd.WithDecoderConcurrency(0))
+	})
+	if z.decoderErr != nil {
+		return nil, z.decoderErr
+	}
+	return z.decoder.DecodeAll(data, nil)
+}
+
+// TableCompression holds the compression settings of one table.
+type TableCompression struct {
+	Enabled bool
+	Codec   string
+	Level   int
+	// MinSize skips values smaller than this many bytes, where the
+	// envelope would eat most of the gain.
+	MinSize int
+	// MinAgeDays turns off compression on write; the background job
+	// compresses content once its article is this many days old.
+	MinAgeDays int
+	// ContentTypes limits attachment compression to matching MIME types
+	// (path.Match patterns such as "text/*"). Empty means all types.
+	ContentTypes []string
+}
+
+// Compression holds the per-table compression settings.
+type Compression struct {
+	Body       TableCompression // article_data_mime.a_body
+	Attachment TableCompression // article_data_mime_attachment.content
+}
+
+// Validate checks that enabled tables name a registered codec.
+func (c *Compression) Validate() error {
+	for table, t := range map[string]TableCompression{
+		"article_data_mime":            c.Body,
+		"article_data_mime_attachment": c.Attachment,
+	} {
+		if !t.Enabled {
+			continue
+		}
+		if _, err := GetCodec(t.Codec); err != nil {
+			return fmt.Errorf("%s: %w", table, err)
+		}
+	}
+	return nil
+}
+
+// AttachmentEligible reports whether an attachment of the given content
+// type is compressed.
+func (t TableCompression) AttachmentEligible(contentType string) bool {
+	if len(t.ContentTypes) == 0 {
+		return true
+	}
+	mediaType := strings.ToLower(strings.TrimSpace(strings.SplitN(contentType, ";", 2)[0]))
+	for _, pattern := range t.ContentTypes {
+		if ok, _ := path.Match(strings.ToLower(pattern), mediaType); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// CompressText returns s in a text envelope, or s unchanged when the table
+// is disabled, s is too small or compression does not make it smaller.
+func (t TableCompression) CompressText(s string) (string, error) {
+	if !t.Enabled || len(s) < t.MinSize || IsCompressedText(s) {
+		return s, nil
+	}
+	codec, err := GetCodec(t.Codec)
+	if err != nil {
+		return s, err
+	}
+	packed, err := codec.Compress([]byte(s), t.Level)
+	if err != nil {
+		return s, fmt.Errorf("compress: %w", err)
+	}
+	out := textEnvelope + codec.Name() + ":" + base64.StdEncoding.EncodeToString(packed)
+	if len(out) >= len(s) {
+		return s, nil
+	}
+	return out, nil
+}
+
+// CompressBytes returns data in a binary envelope, or data unchanged when
+// the table is disabled, data is too small or compression does not help.
+func (t TableCompression) CompressBytes(data []byte) ([]byte, error) {
+	if !t.Enabled || len(data) < t.MinSize || IsCompressedBytes(data) {
+		return data, nil
+	}
+	codec, err := GetCodec(t.Codec)
+	if err != nil {
+		return data, err
+	}
+	packed, err := codec.Compress(data, t.Level)
+	if err != nil {
+		return data, fmt.Errorf("compress: %w", err)
+	}
+	name := codec.Name()
+	out := make([]byte, 0, len(binaryEnvelope)+1+len(name)+len(packed))
+	out = append(out, binaryEnvelope...)
+	out = append(out, byte(len(name)))
+	out = append(out, name...)
+	out = append(out, packed...)
+	if len(out) >= len(data) {
+		return data, nil
+	}
+	return out, nil
+}
+
+// IsCompressedText reports whether s is a text envelope.
+func IsCompressedText(s string) bool {
+	return strings.HasPrefix(s, textEnvelope)
+}
+
+// IsCompressedBytes reports whether data is a binary envelope.
+func IsCompressedBytes(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(binaryEnvelope))
+}
+
+// DecompressText returns the original content of a text column value.
+// Values without an envelope are returned as they are.
+func DecompressText(s string) (string, error) {
+	if !IsCompressedText(s) {
+		return s, nil
+	}
+	rest := s[len(textEnvelope):]
+	sep := strings.IndexByte(rest, ':')
+	if sep < 0 {
+		return s, fmt.Errorf("malformed compressed value")
+	}
+	codec, err := GetCodec(rest[:sep])
+	if err != nil {
+		return s, err
+	}
+	packed, err := base64.StdEncoding.DecodeString(rest[sep+1:])
+	if err != nil {
+		return s, fmt.Errorf("malformed compressed value: %w", err)
+	}
+	data, err := codec.Decompress(packed)
+	if err != nil {
+		return s, fmt.Errorf("decompress: %w", err)
+	}
+	return string(data), nil
+}
+
+// DecompressBytes returns the original content of a binary column value.
+// Values without an envelope are returned as they are.
+func DecompressBytes(data []byte) ([]byte, error) {
+	if !IsCompressedBytes(data) {
+		return data, nil
+	}
+	rest := data[len(binaryEnvelope):]
+	if len(rest) == 0 || len(rest) < 1+int(rest[0]) {
+		return data, fmt.Errorf("malformed compressed value")
+	}
+	codec, err := GetCodec(string(rest[1 : 1+rest[0]]))
+	if err != nil {
+		return data, err
+	}
+	out, err := codec.Decompress(rest[1+rest[0]:])
+	if err != nil {
+		return data, fmt.Errorf("decompress: %w", err)
+	}
+	return out, nil
+}
+
+// DecodeText is DecompressText for read paths that cannot report errors:
+// a value that fails to decompress is returned as stored.
+func DecodeText(s string) string {
+	out, _ := DecompressText(s) //nolint:errcheck // Falls back to the stored value
+	return out
+}
+
+// DecodeBytes is DecompressBytes for read paths that cannot report errors.
+func DecodeBytes(data []byte) []byte {
+	out, _ := DecompressBytes(data) //nolint:errcheck // Falls back to the stored value
+	return out
+}
@@ -0,0 +1,166 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/testutil"
+)
+
+func zstdTable(minSize int) TableCompression {
+	return TableCompression{Enabled: true, Codec: "zstd", Level: 3, MinSize: minSize}
+}
+
+func TestCompressTextRoundTrip(t *testing.T) {
+	body := strings.Repeat("Hello, the printer on floor 3 is out of toner again. ", 40)
+
+	packed, err := zstdTable(512).CompressText(body)
+	require.NoError(t, err)
+	assert.True(t, IsCompressedText(packed))
+	assert.True(t, strings.HasPrefix(packed, "gfz1:zstd:"))
+	assert.Less(t, len(packed), len(body))
+
+	plain, err := DecompressText(packed)
+	require.NoError(t, err)
+	assert.Equal(t, body, plain)
+
+	// Compressing twice is a no-op.
+	again, err := zstdTable(512).CompressText(packed)
+	require.NoError(t, err)
+	assert.Equal(t, packed, again)
+}
+
+func TestCompressTextKeepsValuesThatDoNotShrink(t *testing.T) {
+	short, err := zstdTable(512).CompressText("Thanks!")
+	require.NoError(t, err)
+	assert.Equal(t, "Thanks!", short)
+
+	disabled, err := TableCompression{Codec: "zstd"}.CompressText(strings.Repeat("a", 4096))
+	require.NoError(t, err)
+	assert.False(t, IsCompressedText(disabled))
+}
+
+func TestCompressBytesRoundTrip(t *testing.T) {
+	data := []byte(strings.Repeat("2024-01-01 INFO request handled in 12ms\n", 100))
+
+	packed, err := zstdTable(1024).CompressBytes(data)
+	require.NoError(t, err)
+	assert.True(t, IsCompressedBytes(packed))
+	assert.Equal(t, "zstd", compressedCodec(packed))
+
+	plain, err := DecompressBytes(packed)
+	require.NoError(t, err)
+	assert.Equal(t, data, plain)
+}
+
+func TestDecodeLegacyValues(t *testing.T) {
+	assert.Equal(t, "plain OTRS body", DecodeText("plain OTRS body"))
+	assert.Equal(t, []byte{0x89, 'P', 'N', 'G'}, DecodeBytes([]byte{0x89, 'P', 'N', 'G'}))
+
+	// A value that only looks like an envelope is returned as stored.
+	assert.Equal(t, "gfz1:nope", DecodeText("gfz1:nope"))
+	_, err := DecompressText("gfz1:lz4:AAAA")
+	assert.ErrorContains(t, err, "unknown compression codec")
+}
+
+func TestAttachmentEligible(t *testing.T) {
+	tc := TableCompression{ContentTypes: []string{"text/*", "application/json"}}
+	assert.True(t, tc.AttachmentEligible("text/plain; charset=utf-8"))
+	assert.True(t, tc.AttachmentEligible("Application/JSON"))
+	assert.False(t, tc.AttachmentEligible("image/png"))
+	assert.True(t, TableCompression{}.AttachmentEligible("image/png"))
+}
+
+func TestCompressionValidate(t *testing.T) {
+	c := &Compression{Body: TableCompression{Enabled: true, Codec: "brotli"}}
+	assert.ErrorContains(t, c.Validate(), "article_data_mime: unknown compression codec: brotli")
+
+	c.Body.Enabled = false
+	assert.NoError(t, c.Validate())
+}
+
+// insertRecompressArticle adds an article created at created with the
+// given body.
+func insertRecompressArticle(t *testing.T, db *sql.DB, id int, created time.Time, body string) {
+	t.Helper()
+	_, err := db.Exec(`INSERT INTO article (id, ticket_id, article_sender_type_id, communication_channel_id,
+		is_visible_for_customer, create_time, create_by, change_time, change_by) VALUES (?, 1, 1, 1, 1, ?, 1, ?, 1)`,
+		id, created, created)
+	require.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO article_data_mime (article_id, a_body, incoming_time, create_time, create_by, change_time, change_by)
+		VALUES (?, ?, 0, ?, 1, ?, 1)`, id, body, created, created)
+	require.NoError(t, err)
+}
+
+func TestRecompressorCompressesOldArticles(t *testing.T) {
+	db := testutil.MigratedDB(t)
+	now := time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC)
+	body := strings.Repeat("Please reset my password, I am locked out. ", 50)
+	log := []byte(strings.Repeat("ERROR connection reset by peer\n", 100))
+
+	for id, created := range map[int]time.Time{1: now.AddDate(0, 0, -90), 2: now.AddDate(0, 0, -1)} {
+		insertRecompressArticle(t, db, id, created, body)
+		_, err := db.Exec(`INSERT INTO article_data_mime_attachment (article_id, filename, content_type, content,
+			create_time, create_by, change_time, change_by) VALUES (?, 'error.log', 'text/plain', ?, ?, 1, ?, 1),
+			(?, 'error.png', 'image/png', ?, ?, 1, ?, 1)`, id, log, created, created, id, log, created, created)
+		require.NoError(t, err)
+	}
+
+	c := &Compression{Body: zstdTable(512), Attachment: zstdTable(1024)}
+	c.Body.MinAgeDays, c.Attachment.MinAgeDays = 30, 30
+	c.Attachment.ContentTypes = []string{"text/*"}
+	r := NewRecompressor(db, c)
+	r.now = func() time.Time { return now }
+	r.BatchSize = 1
+
+	stats, err := r.Run(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 2, stats.Changed) // Old body and old text attachment
+	assert.Less(t, stats.BytesAfter, stats.BytesBefore)
+
+	var oldBody, newBody string
+	require.NoError(t, db.QueryRow(`SELECT a_body FROM article_data_mime WHERE article_id = 1`).Scan(&oldBody))
+	require.NoError(t, db.QueryRow(`SELECT a_body FROM article_data_mime WHERE article_id = 2`).Scan(&newBody))
+	assert.True(t, IsCompressedText(oldBody))
+	assert.Equal(t, body, DecodeText(oldBody))
+	assert.Equal(t, body, newBody)
+
+	var png []byte
+	require.NoError(t, db.QueryRow(`SELECT content FROM article_data_mime_attachment WHERE article_id = 1 AND content_type = 'image/png'`).Scan(&png))
+	assert.Equal(t, log, png)
+
+	// A second run finds nothing left to do.
+	stats, err = r.Run(context.Background())
+	require.NoError(t, err)
+	assert.Zero(t, stats.Changed)
+
+	// Decompressing restores the original content for OTRS.
+	r.Decompress = true
+	stats, err = r.Run(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 2, stats.Changed)
+	require.NoError(t, db.QueryRow(`SELECT a_body FROM article_data_mime WHERE article_id = 1`).Scan(&oldBody))
+	assert.Equal(t, body, oldBody)
+}
+
+func TestRecompressorDryRun(t *testing.T) {
+	db := testutil.MigratedDB(t)
+	body := strings.Repeat("dry run ", 200)
+	insertRecompressArticle(t, db, 1, time.Now().AddDate(-1, 0, 0), body)
+
+	r := NewRecompressor(db, &Compression{Body: zstdTable(0)})
+	r.DryRun = true
+	stats, err := r.Run(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, stats.Changed)
+
+	var stored string
+	require.NoError(t, db.QueryRow(`SELECT a_body FROM article_data_mime`).Scan(&stored))
+	assert.Equal(t, body, stored)
+}
@@ -29,6 +29,11 @@ func (d *DatabaseBackend) Store(ctx context.Context, articleID int64, content *A
 	hash := sha256.Sum256(content.Content)
 	checksum := hex.EncodeToString(hash[:])
 
+	stored, err := compressForStore(content)
+	if err != nil {
+		return nil, err
+	}
+
 	// Begin transaction
 	tx, err := d.db.BeginTx(ctx, nil)
 	if err != nil {
@@ -57,7 +62,7 @@ func (d *DatabaseBackend) Store(ctx context.Context, articleID int64, content *A
                 WHERE article_id = ?
             `),
 				articleID,
-				content.Content,
+				stored,
 				content.ContentType,
 				time.Now(),
 				content.CreatedBy,
@@ -66,7 +71,7 @@ func (d *DatabaseBackend) Store(ctx context.Context, articleID int64, content *A
 			res, errExec := tx.ExecContext(ctx, query,
 				articleID,
 				content.Metadata["subject"],
-				content.Content,
+				stored,
 				content.ContentType,
 				time.Now().Unix(),
 				content.CreatedTime,
@@ -83,7 +88,7 @@ func (d *DatabaseBackend) Store(ctx context.Context, articleID int64, content *A
 			err = tx.QueryRowContext(ctx, queryPg,
 				articleID,
 				content.Metadata["subject"],
-				content.Content,
+				stored,
 				content.ContentType,
 				time.Now().Unix(),
 				content.CreatedTime,
@@ -134,7 +139,7 @@ func (d *DatabaseBackend) Store(ctx context.Context, articleID int64, content *A
 			content.FileName,
 			content.ContentType,
 			fmt.Sprintf("%d", content.FileSize),
-			content.Content,
+			stored,
 			content.Metadata["content_id"],
 			content.Metadata["content_alternative"],
 			content.Metadata["disposition"],
@@ -153,7 +158,7 @@ func (d *DatabaseBackend) Store(ctx context.Context, articleID int64, content *A
 			content.FileName,
 			content.ContentType,
 			fmt.Sprintf("%d", content.FileSize),
-			content.Content,
+			stored,
 			content.Metadata["content_id"],
 			content.Metadata["content_alternative"],
 			content.Metadata["disposition"],
@@ -223,6 +228,11 @@ func (d *DatabaseBackend) Retrieve(ctx context.Context, ref *StorageReference) (
 		if subject.Valid {
 			content.Metadata["subject"] = subject.String
 		}
+		body, err := DecompressText(string(content.Content))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress article body: %w", err)
+		}
+		content.Content = []byte(body)
 		content.FileName = "body"
 		content.FileSize = int64(len(content.Content))
 
@@ -270,6 +280,9 @@ func (d *DatabaseBackend) Retrieve(ctx context.Context, ref *StorageReference) (
 		content.Metadata["disposition"] = disposition.String
 	}
 
+	if content.Content, err = DecompressBytes(content.Content); err != nil {
+		return nil, fmt.Errorf("failed to decompress attachment: %w", err)
+	}
 	content.FileSize = int64(len(content.Content))
 
 	return content, nil
@@ -0,0 +1,352 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/goatkit/goatflow/internal/config"
+	"github.com/goatkit/goatflow/internal/database"
+)
+
+var activeCompression atomic.Pointer[Compression]
+
+// SetCompression sets the compression settings used when storing content.
+func SetCompression(c *Compression) {
+	activeCompression.Store(c)
+}
+
+// ActiveCompression returns the settings set by SetCompression. Compression
+// is off until then.
+func ActiveCompression() *Compression {
+	if c := activeCompression.Load(); c != nil {
+		return c
+	}
+	return &Compression{}
+}
+
+// CompressionFromConfig converts the storage.compression section of the
+// application configuration.
+func CompressionFromConfig(cfg config.StorageCompressionConfig) (*Compression, error) {
+	table := func(t config.StorageCompressionTable) TableCompression {
+		codec := t.Codec
+		if codec == "" {
+			codec = "zstd"
+		}
+		return TableCompression{
+			Enabled:      t.Enabled,
+			Codec:        codec,
+			Level:        t.Level,
+			MinSize:      t.MinSize,
+			MinAgeDays:   t.MinAgeDays,
+			ContentTypes: t.ContentTypes,
+		}
+	}
+	c := &Compression{
+		Body:       table(cfg.ArticleBody),
+		Attachment: table(cfg.ArticleAttachment),
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// compressForStore returns the value to write for new content. Tables with
+// a minimum age are left to the Recompressor, so recent articles stay
+// searchable with plain SQL while they are still being worked on.
+func compressForStore(content *ArticleContent) ([]byte, error) {
+	c := ActiveCompression()
+	if content.FileName == "" || content.FileName == "body" {
+		if c.Body.MinAgeDays > 0 {
+			return content.Content, nil
+		}
+		s, err := c.Body.CompressText(string(content.Content))
+		return []byte(s), err
+	}
+	if c.Attachment.MinAgeDays > 0 || !c.Attachment.AttachmentEligible(content.ContentType) {
+		return content.Content, nil
+	}
+	return c.Attachment.CompressBytes(content.Content)
+}
+
+// RecompressStats summarises a Recompressor run.
+type RecompressStats struct {
+	Scanned     int   `json:"scanned"`
+	Changed     int   `json:"changed"`
+	Skipped     int   `json:"skipped"`
+	Failed      int   `json:"failed"`
+	BytesBefore int64 `json:"bytes_before"`
+	BytesAfter  int64 `json:"bytes_after"`
+}
+
+func (s *RecompressStats) add(o *RecompressStats) {
+	s.Scanned += o.Scanned
+	s.Changed += o.Changed
+	s.Skipped += o.Skipped
+	s.Failed += o.Failed
+	s.BytesBefore += o.BytesBefore
+	s.BytesAfter += o.BytesAfter
+}
+
+// Recompressor rewrites stored article bodies and attachments to match the
+// compression settings: uncompressed values old enough are compressed and
+// values written with another codec are recompressed. With Decompress set
+// it instead expands every compressed value, which is needed before handing
+// the database back to OTRS.
+type Recompressor struct {
+	DB          *sql.DB
+	Compression *Compression
+	BatchSize   int
+	// MaxRows stops a run after this many rows per table (0 = no limit),
+	// so the scheduled job does not hog the database. The next run carries
+	// on where the last one stopped.
+	MaxRows    int
+	DryRun     bool
+	Decompress bool
+
+	now          func() time.Time
+	bodyCursor   int64
+	attachCursor int64
+}
+
+// NewRecompressor creates a recompressor for the given settings.
+func NewRecompressor(db *sql.DB, c *Compression) *Recompressor {
+	return &Recompressor{DB: db, Compression: c, BatchSize: 500, now: time.Now}
+}
+
+// Run processes article bodies, then attachments.
+func (r *Recompressor) Run(ctx context.Context) (*RecompressStats, error) {
+	total := &RecompressStats{}
+	for _, run := range []func(context.Context) (*RecompressStats, error){r.runBodies, r.runAttachments} {
+		stats, err := run(ctx)
+		total.add(stats)
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+// cutoff returns the create_time before which articles are processed.
+func (r *Recompressor) cutoff(t TableCompression) time.Time {
+	now := r.now()
+	if r.Decompress {
+		return now.Add(time.Hour) // Every row, including ones written during the run
+	}
+	return now.AddDate(0, 0, -t.MinAgeDays)
+}
+
+func (r *Recompressor) active(t TableCompression) bool {
+	return r.Decompress || t.Enabled
+}
+
+func (r *Recompressor) batchSize() int {
+	if r.BatchSize > 0 {
+		return r.BatchSize
+	}
+	return 500
+}
+
+func (r *Recompressor) runBodies(ctx context.Context) (*RecompressStats, error) {
+	stats := &RecompressStats{}
+	t := r.Compression.Body
+	if !r.active(t) {
+		return stats, nil
+	}
+	query := database.ConvertPlaceholders(`
+		SELECT adm.id, adm.a_body
+		FROM article_data_mime adm
+		JOIN article a ON a.id = adm.article_id
+		WHERE adm.id > ? AND a.create_time < ? AND adm.a_body IS NOT NULL
+		ORDER BY adm.id
+		LIMIT ?`)
+	update := database.ConvertPlaceholders(
+		"UPDATE article_data_mime SET a_body = ? WHERE id = ? AND a_body = ?")
+
+	lastID := r.bodyCursor
+	defer func() { r.bodyCursor = lastID }()
+	for r.MaxRows == 0 || stats.Scanned < r.MaxRows {
+		type row struct {
+			id   int64
+			body string
+		}
+		var batch []row
+		rows, err := r.DB.QueryContext(ctx, query, lastID, r.cutoff(t), r.batchSize())
+		if err != nil {
+			return stats, fmt.Errorf("list article bodies: %w", err)
+		}
+		for rows.Next() {
+			var rw row
+			if err := rows.Scan(&rw.id, &rw.body); err != nil {
+				rows.Close()
+				return stats, fmt.Errorf("scan article body: %w", err)
+			}
+			batch = append(batch, rw)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return stats, fmt.Errorf("list article bodies: %w", err)
+		}
+		if len(batch) == 0 {
+			lastID = 0 // Start over next run
+			break
+		}
+
+		for _, rw := range batch {
+			lastID = rw.id
+			stats.Scanned++
+			out, err := r.rewriteText(t, rw.body)
+			if err != nil {
+				stats.Failed++
+				continue
+			}
+			if out == rw.body {
+				stats.Skipped++
+				continue
+			}
+			stats.Changed++
+			stats.BytesBefore += int64(len(rw.body))
+			stats.BytesAfter += int64(len(out))
+			if r.DryRun {
+				continue
+			}
+			// Matching on the old value leaves bodies edited meanwhile alone.
+			if _, err := r.DB.ExecContext(ctx, update, out, rw.id, rw.body); err != nil {
+				return stats, fmt.Errorf("update article body %d: %w", rw.id, err)
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			return stats, err
+		}
+	}
+	return stats, nil
+}
+
+func (r *Recompressor) runAttachments(ctx context.Context) (*RecompressStats, error) {
+	stats := &RecompressStats{}
+	t := r.Compression.Attachment
+	if !r.active(t) {
+		return stats, nil
+	}
+	query := database.ConvertPlaceholders(`
+		SELECT att.id, att.content_type, att.content
+		FROM article_data_mime_attachment att
+		JOIN article a ON a.id = att.article_id
+		WHERE att.id > ? AND a.create_time < ?
+		ORDER BY att.id
+		LIMIT ?`)
+	update := database.ConvertPlaceholders(
+		"UPDATE article_data_mime_attachment SET content = ? WHERE id = ? AND content = ?")
+
+	lastID := r.attachCursor
+	defer func() { r.attachCursor = lastID }()
+	for r.MaxRows == 0 || stats.Scanned < r.MaxRows {
+		type row struct {
+			id          int64
+			contentType sql.NullString
+			content     []byte
+		}
+		var batch []row
+		rows, err := r.DB.QueryContext(ctx, query, lastID, r.cutoff(t), r.batchSize())
+		if err != nil {
+			return stats, fmt.Errorf("list attachments: %w", err)
+		}
+		for rows.Next() {
+			var rw row
+			if err := rows.Scan(&rw.id, &rw.contentType, &rw.content); err != nil {
+				rows.Close()
+				return stats, fmt.Errorf("scan attachment: %w", err)
+			}
+			batch = append(batch, rw)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return stats, fmt.Errorf("list attachments: %w", err)
+		}
+		if len(batch) == 0 {
+			lastID = 0 // Start over next run
+			break
+		}
+
+		for _, rw := range batch {
+			lastID = rw.id
+			stats.Scanned++
+			if !r.Decompress && !t.AttachmentEligible(rw.contentType.String) {
+				stats.Skipped++
+				continue
+			}
+			out, err := r.rewriteBytes(t, rw.content)
+			if err != nil {
+				stats.Failed++
+				continue
+			}
+			if string(out) == string(rw.content) {
+				stats.Skipped++
+				continue
+			}
+			stats.Changed++
+			stats.BytesBefore += int64(len(rw.content))
+			stats.BytesAfter += int64(len(out))
+			if r.DryRun {
+				continue
+			}
+			if _, err := r.DB.ExecContext(ctx, update, out, rw.id, rw.content); err != nil {
+				return stats, fmt.Errorf("update attachment %d: %w", rw.id, err)
+			}
+		}
+		if err := ctx.Err(); err != nil {
+			return stats, err
+		}
+	}
+	return stats, nil
+}
+
+// rewriteText returns the value a body should have under the settings.
+// Values already compressed with the configured codec are kept.
+func (r *Recompressor) rewriteText(t TableCompression, s string) (string, error) {
+	if IsCompressedText(s) {
+		if !r.Decompress && strings.HasPrefix(s, textEnvelope+t.Codec+":") {
+			return s, nil
+		}
+		plain, err := DecompressText(s)
+		if err != nil || r.Decompress {
+			return plain, err
+		}
+		s = plain
+	}
+	if r.Decompress {
+		return s, nil
+	}
+	return t.CompressText(s)
+}
+
+// rewriteBytes is rewriteText for attachment content.
+func (r *Recompressor) rewriteBytes(t TableCompression, data []byte) ([]byte, error) {
+	if IsCompressedBytes(data) {
+		if !r.Decompress && compressedCodec(data) == t.Codec {
+			return data, nil
+		}
+		plain, err := DecompressBytes(data)
+		if err != nil || r.Decompress {
+			return plain, err
+		}
+		data = plain
+	}
+	if r.Decompress {
+		return data, nil
+	}
+	return t.CompressBytes(data)
+}
+
+// compressedCodec returns the codec name of a binary envelope.
+func compressedCodec(data []byte) string {
+	rest := data[len(binaryEnvelope):]
+	if len(rest) == 0 || len(rest) < 1+int(rest[0]) {
+		return ""
+	}
+	return string(rest[1 : 1+rest[0]])
+}
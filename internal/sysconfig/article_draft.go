@@ -0,0 +1,53 @@
+package sysconfig
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+)
+
+// ArticleDraftConfig holds the article draft settings stored in sysconfig.
+type ArticleDraftConfig struct {
+	// RetentionDays is how many days a draft is kept after its last change
+	// (0 = drafts are kept until posted or discarded).
+	RetentionDays int `json:"retention_days"`
+}
+
+// articleDraftRetentionKey is the sysconfig name of the draft retention.
+const articleDraftRetentionKey = "Ticket::Frontend::ArticleDraft::RetentionDays"
+
+// DefaultArticleDraftConfig returns the built-in article draft settings.
+func DefaultArticleDraftConfig() ArticleDraftConfig {
+	return ArticleDraftConfig{RetentionDays: 30}
+}
+
+// LoadArticleDraftConfig loads the article draft settings from sysconfig.
+func LoadArticleDraftConfig(db *sql.DB) (ArticleDraftConfig, error) {
+	cfg := DefaultArticleDraftConfig()
+	if db == nil {
+		return cfg, nil
+	}
+	loadSysconfigValue(db, articleDraftRetentionKey, &cfg.RetentionDays)
+	return cfg, nil
+}
+
+// SaveArticleDraftConfig persists the article draft settings as sysconfig
+// overrides.
+func SaveArticleDraftConfig(db *sql.DB, cfg ArticleDraftConfig, userID int) error {
+	if db == nil {
+		return fmt.Errorf("database connection unavailable")
+	}
+	def := portalKeyDef{
+		name:        articleDraftRetentionKey,
+		description: "Days an article draft is kept after its last change; 0 keeps drafts until posted or discarded.",
+		xml:         `{"type":"integer","default":30}`,
+		defaultVal:  "30",
+	}
+	if err := ensureSysconfigDefault(db, def.name, def, "Frontend::Agent::View::TicketZoom", "Ticket.xml", userID); err != nil {
+		return fmt.Errorf("sysconfig unavailable: %w", err)
+	}
+	if err := upsertSysconfigValue(db, def.name, strconv.Itoa(cfg.RetentionDays), userID); err != nil {
+		return fmt.Errorf("sysconfig unavailable: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package sysconfig
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// AttachmentPolicy limits the attachments a role may upload. Zero values
+// and an empty type list inherit from the policy below; see
+// AttachmentPolicyConfig.For.
+type AttachmentPolicy struct {
+	// MaxFileSize is the largest attachment in bytes.
+	MaxFileSize int64 `json:"max_file_size,omitempty"`
+
+	// AllowedTypes are the MIME types that may be uploaded; "image/*"
+	// allows a whole family. Empty allows all types.
+	AllowedTypes []string `json:"allowed_types,omitempty"`
+
+	// MaxTicketTotal is how many bytes all attachments of a ticket may add
+	// up to.
+	MaxTicketTotal int64 `json:"max_ticket_total,omitempty"`
+}
+
+// AllowsType reports whether the policy allows a MIME type. Unknown types
+// are left to the blocked extension list.
+func (p AttachmentPolicy) AllowsType(contentType string) bool {
+	if len(p.AllowedTypes) == 0 || contentType == "" || contentType == "application/octet-stream" {
+		return true
+	}
+	contentType = strings.ToLower(strings.TrimSpace(strings.SplitN(contentType, ";", 2)[0]))
+	for _, t := range p.AllowedTypes {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if t == contentType || t == "*/*" {
+			return true
+		}
+		if family, ok := strings.CutSuffix(t, "/*"); ok && strings.HasPrefix(contentType, family+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// AttachmentPolicyConfig holds the upload policies: the default, and
+// overrides per role.
+type AttachmentPolicyConfig struct {
+	Default AttachmentPolicy `json:"default"`
+
+	// Roles overrides the default per role: "Admin", "Agent" or "Customer".
+	Roles map[string]AttachmentPolicy `json:"roles"`
+}
+
+// attachmentPolicyKey is the sysconfig name holding the policies as JSON.
+const attachmentPolicyKey = "Core::AttachmentPolicy"
+
+// DefaultAttachmentPolicyConfig returns the built-in policies: none, so the
+// storage.attachments limits of the configuration file apply to everyone.
+func DefaultAttachmentPolicyConfig() AttachmentPolicyConfig {
+	return AttachmentPolicyConfig{Roles: map[string]AttachmentPolicy{}}
+}
+
+// For returns the policy of a role: the role's override on top of the
+// default, on top of base.
+func (c AttachmentPolicyConfig) For(role string, base AttachmentPolicy) AttachmentPolicy {
+	p := base
+	overrides := []AttachmentPolicy{c.Default}
+	if r, ok := c.Roles[role]; ok {
+		overrides = append(overrides, r)
+	}
+	for _, o := range overrides {
+		if o.MaxFileSize > 0 {
+			p.MaxFileSize = o.MaxFileSize
+		}
+		if len(o.AllowedTypes) > 0 {
+			p.AllowedTypes = o.AllowedTypes
+		}
+		if o.MaxTicketTotal > 0 {
+			p.MaxTicketTotal = o.MaxTicketTotal
+		}
+	}
+	return p
+}
+
+// LoadAttachmentPolicyConfig loads the upload policies from sysconfig.
+func LoadAttachmentPolicyConfig(db *sql.DB) (AttachmentPolicyConfig, error) {
+	cfg := DefaultAttachmentPolicyConfig()
+	if db == nil {
+		return cfg, nil
+	}
+	raw, ok := sysconfigValue(db, attachmentPolicyKey)
+	if !ok || raw == "" {
+		return cfg, nil
+	}
+	if err := json.Unmarshal([]byte(raw), &cfg); err != nil {
+		return DefaultAttachmentPolicyConfig(), fmt.Errorf("invalid %s: %w", attachmentPolicyKey, err)
+	}
+	if cfg.Roles == nil {
+		cfg.Roles = map[string]AttachmentPolicy{}
+	}
+	return cfg, nil
+}
+
+// SaveAttachmentPolicyConfig persists the upload policies as a sysconfig
+// override.
+func SaveAttachmentPolicyConfig(db *sql.DB, cfg AttachmentPolicyConfig, userID int) error {
+	if db == nil {
+		return fmt.Errorf("database connection unavailable")
+	}
+	if cfg.Roles == nil {
+		cfg.Roles = map[string]AttachmentPolicy{}
+	}
+	value, err := json.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+	def := portalKeyDef{
+		name:        attachmentPolicyKey,
+		description: "Attachment upload policies as JSON: maximum file size, allowed MIME types and total per ticket, by default and per role; set through the attachment policy admin API.",
+		xml:         `{"type":"textarea","default":"{}"}`,
+		defaultVal:  `{}`,
+	}
+	if err := ensureSysconfigDefault(db, def.name, def, "Core::Ticket", "Ticket.xml", userID); err != nil {
+		return fmt.Errorf("sysconfig unavailable: %w", err)
+	}
+	if err := upsertSysconfigValue(db, def.name, string(value), userID); err != nil {
+		return fmt.Errorf("sysconfig unavailable: %w", err)
+	}
+	return nil
+}
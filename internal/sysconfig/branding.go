@@ -0,0 +1,110 @@
+package sysconfig
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// BrandingConfig holds the branding stored in sysconfig: globally, or per
+// customer company (tenant) with the customer ID appended to each name. An
+// empty tenant value inherits the global one.
+type BrandingConfig struct {
+	ProductName  string `json:"product_name"`
+	PrimaryColor string `json:"primary_color"` // #rrggbb; empty keeps the theme's
+	AccentColor  string `json:"accent_color"`  // #rrggbb; empty keeps the theme's
+	LoginTitle   string `json:"login_title"`
+	LoginText    string `json:"login_text"`
+	EmailHeader  string `json:"email_header"`
+	EmailFooter  string `json:"email_footer"`
+}
+
+func brandingKeyDefs() []portalKeyDef {
+	return []portalKeyDef{
+		{"Branding::ProductName", "Product name shown in page titles, the navigation and the login pages.", `{"type":"string","default":"GoatFlow"}`, "GoatFlow"},
+		{"Branding::PrimaryColor", "Primary color (#rrggbb) replacing the theme's; empty keeps the theme's.", `{"type":"string","default":""}`, ""},
+		{"Branding::AccentColor", "Accent color (#rrggbb) replacing the theme's; empty keeps the theme's.", `{"type":"string","default":""}`, ""},
+		{"Branding::LoginTitle", "Heading of the login pages; empty shows the product name.", `{"type":"string","default":""}`, ""},
+		{"Branding::LoginText", "Text shown below the heading of the login pages.", `{"type":"string","default":""}`, ""},
+		{"Branding::EmailHeader", "Text or HTML added above the body of outgoing ticket emails.", `{"type":"string","default":""}`, ""},
+		{"Branding::EmailFooter", "Text or HTML added below the body of outgoing ticket emails.", `{"type":"string","default":""}`, ""},
+	}
+}
+
+// fields returns the settings of cfg in the order of brandingKeyDefs.
+func (cfg *BrandingConfig) fields() []*string {
+	return []*string{&cfg.ProductName, &cfg.PrimaryColor, &cfg.AccentColor, &cfg.LoginTitle, &cfg.LoginText,
+		&cfg.EmailHeader, &cfg.EmailFooter}
+}
+
+// DefaultBrandingConfig returns the built-in branding.
+func DefaultBrandingConfig() BrandingConfig {
+	return BrandingConfig{ProductName: "GoatFlow"}
+}
+
+// LoadBrandingConfig returns the effective branding of a tenant: its own
+// values over the global ones. An empty customerID returns the global
+// branding.
+func LoadBrandingConfig(db *sql.DB, customerID string) (BrandingConfig, error) {
+	cfg := DefaultBrandingConfig()
+	if db == nil {
+		return cfg, nil
+	}
+	scopes := []string{""}
+	if customerID = strings.TrimSpace(customerID); customerID != "" {
+		scopes = append(scopes, customerID)
+	}
+	for _, scope := range scopes {
+		overrides, err := LoadBrandingOverrides(db, scope)
+		if err != nil {
+			return cfg, err
+		}
+		values := overrides.fields()
+		for i, field := range cfg.fields() {
+			if *values[i] != "" {
+				*field = *values[i]
+			}
+		}
+	}
+	return cfg, nil
+}
+
+// LoadBrandingOverrides returns the values stored for one scope: the global
+// branding for an empty customerID, else only the tenant's own values.
+func LoadBrandingOverrides(db *sql.DB, customerID string) (BrandingConfig, error) {
+	var cfg BrandingConfig
+	if db == nil {
+		return cfg, fmt.Errorf("database connection unavailable")
+	}
+	fields := cfg.fields()
+	for i, def := range brandingKeyDefs() {
+		if val, ok := sysconfigValue(db, portalKeyName(def.name, customerID)); ok {
+			*fields[i] = val
+		}
+	}
+	return cfg, nil
+}
+
+// SaveBrandingConfig persists the branding of a scope as sysconfig
+// overrides: the global branding for an empty customerID, else a tenant's.
+func SaveBrandingConfig(db *sql.DB, customerID string, cfg BrandingConfig, userID int) error {
+	if db == nil {
+		return fmt.Errorf("database connection unavailable")
+	}
+	customerID = strings.TrimSpace(customerID)
+	fields := cfg.fields()
+	for i, def := range brandingKeyDefs() {
+		name := portalKeyName(def.name, customerID)
+		if customerID != "" {
+			// Tenant values inherit the global ones until set
+			def.xml, def.defaultVal = `{"type":"string","default":""}`, ""
+		}
+		if err := ensureSysconfigDefault(db, name, def, "Frontend::Base::Branding", "Framework.xml", userID); err != nil {
+			return fmt.Errorf("sysconfig unavailable: %w", err)
+		}
+		if err := upsertSysconfigValue(db, name, *fields[i], userID); err != nil {
+			return fmt.Errorf("sysconfig unavailable: %w", err)
+		}
+	}
+	return nil
+}
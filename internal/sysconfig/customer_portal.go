@@ -20,6 +20,12 @@ type CustomerPortalConfig struct {
 	Title         string
 	FooterText    string
 	LandingPage   string
+
+	// Domain, Theme and LogoURL are set from the customer portal domain the
+	// request was made on; they are empty on the main host.
+	Domain  string
+	Theme   string
+	LogoURL string
 }
 
 type portalKeyDef struct {
@@ -125,6 +131,12 @@ func LoadCustomerPortalConfigForCompany(db *sql.DB, customerID string) (Customer
 }
 
 func ensurePortalDefault(db *sql.DB, targetName string, def portalKeyDef, userID int) error {
+	return ensureSysconfigDefault(db, targetName, def, "Frontend::Customer::Portal", "CustomerPortal.xml", userID)
+}
+
+// ensureSysconfigDefault creates the sysconfig_default row for targetName
+// when it does not exist yet, so overrides can be stored against it.
+func ensureSysconfigDefault(db *sql.DB, targetName string, def portalKeyDef, navigation, xmlFilename string, userID int) error {
 	if db == nil {
 		return fmt.Errorf("database connection unavailable")
 	}
@@ -152,9 +164,9 @@ func ensurePortalDefault(db *sql.DB, targetName string, def portalKeyDef, userID
 				exclusive_lock_guid, exclusive_lock_user_id, exclusive_lock_expiry_time,
 				create_time, create_by, change_time, change_by
 			) VALUES (
-				?, ?, 'Frontend::Customer::Portal', 0, 0, 0, 1,
+				?, ?, ?, 0, 0, 0, 1,
 				0, 1, 1, NULL,
-				?, ?, 'CustomerPortal.xml', ?, 0,
+				?, ?, ?, ?, 0,
 				'', NULL, NULL,
 				CURRENT_TIMESTAMP, ?, CURRENT_TIMESTAMP, ?
 			)
@@ -165,7 +177,7 @@ func ensurePortalDefault(db *sql.DB, targetName string, def portalKeyDef, userID
 				effective_value = VALUES(effective_value),
 				change_time = CURRENT_TIMESTAMP,
 				change_by = VALUES(change_by)
-		`), targetName, def.description, def.xml, def.xml, def.defaultVal, userID, userID)
+		`), targetName, def.description, navigation, def.xml, def.xml, xmlFilename, def.defaultVal, userID, userID)
 		return insertErr
 	}
 
@@ -177,9 +189,9 @@ func ensurePortalDefault(db *sql.DB, targetName string, def portalKeyDef, userID
 			exclusive_lock_guid, exclusive_lock_user_id, exclusive_lock_expiry_time,
 			create_time, create_by, change_time, change_by
 		) VALUES (
-			?, ?, 'Frontend::Customer::Portal', 0, 0, 0, 1,
+			?, ?, ?, 0, 0, 0, 1,
 			0, 1, 1, NULL,
-			?, ?, 'CustomerPortal.xml', ?, 0,
+			?, ?, ?, ?, 0,
 			'', NULL, NULL,
 			CURRENT_TIMESTAMP, ?, CURRENT_TIMESTAMP, ?
 		)
@@ -191,7 +203,7 @@ func ensurePortalDefault(db *sql.DB, targetName string, def portalKeyDef, userID
 			change_time = EXCLUDED.change_time,
 			change_by = EXCLUDED.change_by
 		RETURNING id
-	`), targetName, def.description, def.xml, def.xml, def.defaultVal, userID).Scan(&id)
+	`), targetName, def.description, navigation, def.xml, def.xml, xmlFilename, def.defaultVal, userID, userID).Scan(&id)
 	return insertErr
 }
 
@@ -0,0 +1,126 @@
+package sysconfig
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// CustomerRegistrationConfig holds the customer portal self-registration
+// settings.
+type CustomerRegistrationConfig struct {
+	// Enabled offers the signup form on the customer portal.
+	Enabled bool `json:"enabled"`
+
+	// RequireApproval keeps verified registrations pending until an admin
+	// approves them; otherwise the account is created on verification.
+	RequireApproval bool `json:"require_approval"`
+
+	// DomainMappings assign registrations to a customer company by the
+	// domain of their email address.
+	DomainMappings []CustomerRegistrationDomain `json:"domain_mappings"`
+
+	// MappedDomainsOnly refuses addresses whose domain has no mapping.
+	MappedDomainsOnly bool `json:"mapped_domains_only"`
+
+	// DefaultCustomerID is the company of unmapped domains; when empty the
+	// email domain itself is used as the customer ID.
+	DefaultCustomerID string `json:"default_customer_id"`
+
+	// TokenValidHours is how long verification links stay valid.
+	TokenValidHours int `json:"token_valid_hours"`
+
+	Captcha CaptchaConfig `json:"captcha"`
+}
+
+// CustomerRegistrationDomain maps an email domain to a customer company.
+type CustomerRegistrationDomain struct {
+	Domain     string `json:"domain"`
+	CustomerID string `json:"customer_id"`
+}
+
+// CaptchaConfig selects the CAPTCHA shown on the signup form. An empty
+// provider shows none.
+type CaptchaConfig struct {
+	Provider string `json:"provider"`
+	SiteKey  string `json:"site_key"`
+	Secret   string `json:"secret,omitempty"`
+}
+
+// customerRegistrationKey is the sysconfig name holding the settings as JSON.
+const customerRegistrationKey = "CustomerPortal::Registration"
+
+// DefaultCustomerRegistrationConfig returns the built-in settings:
+// registration off, links valid for a day.
+func DefaultCustomerRegistrationConfig() CustomerRegistrationConfig {
+	return CustomerRegistrationConfig{TokenValidHours: 24}
+}
+
+// CustomerIDFor returns the customer company of an email address and
+// whether its domain is mapped. Subdomains match their parent's mapping.
+func (c CustomerRegistrationConfig) CustomerIDFor(email string) (string, bool) {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return c.DefaultCustomerID, false
+	}
+	domain := strings.ToLower(email[at+1:])
+	for _, m := range c.DomainMappings {
+		d := strings.ToLower(m.Domain)
+		if domain == d || strings.HasSuffix(domain, "."+d) {
+			return m.CustomerID, true
+		}
+	}
+	if c.DefaultCustomerID != "" {
+		return c.DefaultCustomerID, false
+	}
+	return domain, false
+}
+
+// LoadCustomerRegistrationConfig loads the self-registration settings from
+// sysconfig.
+func LoadCustomerRegistrationConfig(db *sql.DB) (CustomerRegistrationConfig, error) {
+	cfg := DefaultCustomerRegistrationConfig()
+	if db == nil {
+		return cfg, nil
+	}
+	raw, ok := sysconfigValue(db, customerRegistrationKey)
+	if !ok || raw == "" {
+		return cfg, nil
+	}
+	if err := json.Unmarshal([]byte(raw), &cfg); err != nil {
+		return DefaultCustomerRegistrationConfig(), fmt.Errorf("invalid %s: %w", customerRegistrationKey, err)
+	}
+	if cfg.TokenValidHours <= 0 {
+		cfg.TokenValidHours = DefaultCustomerRegistrationConfig().TokenValidHours
+	}
+	return cfg, nil
+}
+
+// SaveCustomerRegistrationConfig persists the self-registration settings as
+// a sysconfig override.
+func SaveCustomerRegistrationConfig(db *sql.DB, cfg CustomerRegistrationConfig, userID int) error {
+	if db == nil {
+		return fmt.Errorf("database connection unavailable")
+	}
+	if cfg.DomainMappings == nil {
+		cfg.DomainMappings = []CustomerRegistrationDomain{}
+	}
+	value, err := json.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+	def := portalKeyDef{
+		name:        customerRegistrationKey,
+		description: "Customer self-registration settings as JSON: enabled, approval, domain mappings and CAPTCHA; set through the customer registration admin API.",
+		xml:         `{"type":"textarea","default":"{\"enabled\":false}"}`,
+		defaultVal:  `{"enabled":false}`,
+	}
+	if err := ensurePortalDefault(db, def.name, def, userID); err != nil {
+		return fmt.Errorf("sysconfig unavailable: %w", err)
+	}
+	if err := upsertSysconfigValue(db, def.name, string(value), userID); err != nil {
+		return fmt.Errorf("sysconfig unavailable: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package sysconfig
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+)
+
+// EmailLoopConfig holds the mail loop and bounce settings stored in
+// sysconfig.
+type EmailLoopConfig struct {
+	// MaxEmailsPerAddress is how many automatic emails, such as auto
+	// responses, one address gets per day (OTRS PostmasterMaxEmails).
+	MaxEmailsPerAddress int `json:"max_emails_per_address"`
+
+	// SoftBounceLimit is how many soft bounces within a week pause auto
+	// responses to an address. A hard bounce always does.
+	SoftBounceLimit int `json:"soft_bounce_limit"`
+}
+
+// Sysconfig names of the email loop settings.
+const (
+	emailLoopMaxEmailsKey       = "PostmasterMaxEmails"
+	emailLoopSoftBounceLimitKey = "PostMaster::Bounce::SoftBounceLimit"
+)
+
+// DefaultEmailLoopConfig returns the built-in email loop settings.
+func DefaultEmailLoopConfig() EmailLoopConfig {
+	return EmailLoopConfig{MaxEmailsPerAddress: 40, SoftBounceLimit: 3}
+}
+
+// LoadEmailLoopConfig loads the email loop settings from sysconfig.
+func LoadEmailLoopConfig(db *sql.DB) (EmailLoopConfig, error) {
+	cfg := DefaultEmailLoopConfig()
+	if db == nil {
+		return cfg, nil
+	}
+	loadSysconfigValue(db, emailLoopMaxEmailsKey, &cfg.MaxEmailsPerAddress)
+	loadSysconfigValue(db, emailLoopSoftBounceLimitKey, &cfg.SoftBounceLimit)
+	return cfg, nil
+}
+
+// SaveEmailLoopConfig persists the email loop settings as sysconfig
+// overrides.
+func SaveEmailLoopConfig(db *sql.DB, cfg EmailLoopConfig, userID int) error {
+	if db == nil {
+		return fmt.Errorf("database connection unavailable")
+	}
+	for _, setting := range []struct {
+		def   portalKeyDef
+		value int
+	}{
+		{portalKeyDef{
+			name:        emailLoopMaxEmailsKey,
+			description: "Maximum number of automatic emails, such as auto responses, sent to one address per day.",
+			xml:         `{"type":"integer","default":40}`,
+			defaultVal:  "40",
+		}, cfg.MaxEmailsPerAddress},
+		{portalKeyDef{
+			name:        emailLoopSoftBounceLimitKey,
+			description: "Number of soft bounces within a week after which auto responses to an address are paused.",
+			xml:         `{"type":"integer","default":3}`,
+			defaultVal:  "3",
+		}, cfg.SoftBounceLimit},
+	} {
+		if err := ensureSysconfigDefault(db, setting.def.name, setting.def, "Core::Email::PostMaster", "Framework.xml", userID); err != nil {
+			return fmt.Errorf("sysconfig unavailable: %w", err)
+		}
+		if err := upsertSysconfigValue(db, setting.def.name, strconv.Itoa(setting.value), userID); err != nil {
+			return fmt.Errorf("sysconfig unavailable: %w", err)
+		}
+	}
+	return nil
+}
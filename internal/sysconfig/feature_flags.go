@@ -0,0 +1,71 @@
+package sysconfig
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+)
+
+// FeatureFlag is a dark-launched feature that can be switched on at runtime,
+// for everyone or for part of the agents.
+type FeatureFlag struct {
+	Key         string `json:"key"`
+	Description string `json:"description,omitempty"`
+
+	// Enabled is the master switch; a disabled flag is off for everyone.
+	Enabled bool `json:"enabled"`
+
+	// Percentage is the share of users (0-100) the flag is on for, picked by
+	// a stable hash of the flag key and user ID.
+	Percentage int `json:"percentage"`
+
+	// Groups are group names whose members get the flag whatever the
+	// percentage.
+	Groups []string `json:"groups,omitempty"`
+}
+
+// featureFlagsKey is the sysconfig name holding all flags as a JSON list.
+const featureFlagsKey = "Core::FeatureFlags"
+
+// LoadFeatureFlags loads the feature flags from sysconfig.
+func LoadFeatureFlags(db *sql.DB) ([]FeatureFlag, error) {
+	if db == nil {
+		return nil, nil
+	}
+	raw, ok := sysconfigValue(db, featureFlagsKey)
+	if !ok || raw == "" {
+		return nil, nil
+	}
+	var flags []FeatureFlag
+	if err := json.Unmarshal([]byte(raw), &flags); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", featureFlagsKey, err)
+	}
+	return flags, nil
+}
+
+// SaveFeatureFlags persists the feature flags as a sysconfig override.
+func SaveFeatureFlags(db *sql.DB, flags []FeatureFlag, userID int) error {
+	if db == nil {
+		return fmt.Errorf("database connection unavailable")
+	}
+	if flags == nil {
+		flags = []FeatureFlag{}
+	}
+	value, err := json.Marshal(flags)
+	if err != nil {
+		return err
+	}
+	def := portalKeyDef{
+		name:        featureFlagsKey,
+		description: "Feature flags as a JSON list of key, description, enabled, percentage and groups; managed under Admin > Feature Flags.",
+		xml:         `{"type":"textarea","default":"[]"}`,
+		defaultVal:  "[]",
+	}
+	if err := ensureSysconfigDefault(db, def.name, def, "Core", "Framework.xml", userID); err != nil {
+		return fmt.Errorf("sysconfig unavailable: %w", err)
+	}
+	if err := upsertSysconfigValue(db, def.name, string(value), userID); err != nil {
+		return fmt.Errorf("sysconfig unavailable: %w", err)
+	}
+	return nil
+}
@@ -2,7 +2,9 @@ package sysconfig
 
 import (
 	"database/sql"
+	"fmt"
 	"regexp"
+	"strconv"
 	"strings"
 	"unicode"
 
@@ -31,8 +33,19 @@ type PasswordPolicy struct {
 	PasswordNeedDigit bool `json:"password_need_digit"`
 
 	// PasswordMaxLoginFailed is the max failed login attempts before account locked (0 = disabled).
-	// Note: This is only used for agents, not customers in OTRS.
 	PasswordMaxLoginFailed int `json:"password_max_login_failed"`
+
+	// PasswordHistory is the number of recent passwords that cannot be reused,
+	// the current one included (0 = disabled).
+	PasswordHistory int `json:"password_history"`
+
+	// PasswordMaxValidTimeInDays is the number of days after which a password
+	// must be changed (0 = never expires).
+	PasswordMaxValidTimeInDays int `json:"password_max_valid_time_in_days"`
+
+	// PasswordBreachCheck rejects passwords found in known data breaches,
+	// looked up with the HaveIBeenPwned range API.
+	PasswordBreachCheck bool `json:"password_breach_check"`
 }
 
 // PasswordValidationError holds details about why password validation failed.
@@ -50,6 +63,9 @@ func DefaultCustomerPasswordPolicy() PasswordPolicy {
 		PasswordMin2Characters:            false,
 		PasswordNeedDigit:                 false,
 		PasswordMaxLoginFailed:            0,
+		PasswordHistory:                   0,
+		PasswordMaxValidTimeInDays:        0,
+		PasswordBreachCheck:               false,
 	}
 }
 
@@ -62,6 +78,31 @@ func DefaultAgentPasswordPolicy() PasswordPolicy {
 		PasswordMin2Characters:            false,
 		PasswordNeedDigit:                 false,
 		PasswordMaxLoginFailed:            0,
+		PasswordHistory:                   0,
+		PasswordMaxValidTimeInDays:        0,
+		PasswordBreachCheck:               false,
+	}
+}
+
+// Sysconfig name prefixes of the password policies. The OTRS setting names
+// are CustomerPreferencesGroups###Password and PreferencesGroups###Password.
+const (
+	customerPasswordPolicyPrefix = "CustomerPreferencesGroups###Password::"
+	agentPasswordPolicyPrefix    = "PreferencesGroups###Password::"
+)
+
+// policySettings maps each policy setting name, without prefix, to its field.
+func (p *PasswordPolicy) policySettings() map[string]interface{} {
+	return map[string]interface{}{
+		"PasswordRegExp":                    &p.PasswordRegExp,
+		"PasswordMinSize":                   &p.PasswordMinSize,
+		"PasswordMin2Lower2UpperCharacters": &p.PasswordMin2Lower2UpperCharacters,
+		"PasswordMin2Characters":            &p.PasswordMin2Characters,
+		"PasswordNeedDigit":                 &p.PasswordNeedDigit,
+		"PasswordMaxLoginFailed":            &p.PasswordMaxLoginFailed,
+		"PasswordHistory":                   &p.PasswordHistory,
+		"PasswordMaxValidTimeInDays":        &p.PasswordMaxValidTimeInDays,
+		"PasswordBreachCheck":               &p.PasswordBreachCheck,
 	}
 }
 
@@ -70,17 +111,8 @@ func LoadCustomerPasswordPolicy(db *sql.DB) (PasswordPolicy, error) {
 	policy := DefaultCustomerPasswordPolicy()
 
 	// Try to load from sysconfig_default/sysconfig_modified
-	// The OTRS setting name is CustomerPreferencesGroups###Password
-	settings := map[string]interface{}{
-		"CustomerPreferencesGroups###Password::PasswordRegExp":                    &policy.PasswordRegExp,
-		"CustomerPreferencesGroups###Password::PasswordMinSize":                   &policy.PasswordMinSize,
-		"CustomerPreferencesGroups###Password::PasswordMin2Lower2UpperCharacters": &policy.PasswordMin2Lower2UpperCharacters,
-		"CustomerPreferencesGroups###Password::PasswordMin2Characters":            &policy.PasswordMin2Characters,
-		"CustomerPreferencesGroups###Password::PasswordNeedDigit":                 &policy.PasswordNeedDigit,
-	}
-
-	for name, target := range settings {
-		loadSysconfigValue(db, name, target)
+	for name, target := range policy.policySettings() {
+		loadSysconfigValue(db, customerPasswordPolicyPrefix+name, target)
 	}
 
 	return policy, nil
@@ -90,21 +122,51 @@ func LoadCustomerPasswordPolicy(db *sql.DB) (PasswordPolicy, error) {
 func LoadAgentPasswordPolicy(db *sql.DB) (PasswordPolicy, error) {
 	policy := DefaultAgentPasswordPolicy()
 
-	// The OTRS setting name is PreferencesGroups###Password
-	settings := map[string]interface{}{
-		"PreferencesGroups###Password::PasswordRegExp":                    &policy.PasswordRegExp,
-		"PreferencesGroups###Password::PasswordMinSize":                   &policy.PasswordMinSize,
-		"PreferencesGroups###Password::PasswordMin2Lower2UpperCharacters": &policy.PasswordMin2Lower2UpperCharacters,
-		"PreferencesGroups###Password::PasswordMin2Characters":            &policy.PasswordMin2Characters,
-		"PreferencesGroups###Password::PasswordNeedDigit":                 &policy.PasswordNeedDigit,
-		"PreferencesGroups###Password::PasswordMaxLoginFailed":            &policy.PasswordMaxLoginFailed,
+	for name, target := range policy.policySettings() {
+		loadSysconfigValue(db, agentPasswordPolicyPrefix+name, target)
+	}
+
+	return policy, nil
+}
+
+// SaveCustomerPasswordPolicy persists the customer password policy as
+// sysconfig overrides.
+func SaveCustomerPasswordPolicy(db *sql.DB, policy PasswordPolicy, userID int) error {
+	return savePasswordPolicy(db, customerPasswordPolicyPrefix, "Frontend::Customer::View::Preferences", policy, userID)
+}
+
+// SaveAgentPasswordPolicy persists the agent password policy as sysconfig
+// overrides.
+func SaveAgentPasswordPolicy(db *sql.DB, policy PasswordPolicy, userID int) error {
+	return savePasswordPolicy(db, agentPasswordPolicyPrefix, "Frontend::Agent::View::Preferences", policy, userID)
+}
+
+func savePasswordPolicy(db *sql.DB, prefix, navigation string, policy PasswordPolicy, userID int) error {
+	if db == nil {
+		return fmt.Errorf("database connection unavailable")
 	}
 
-	for name, target := range settings {
-		loadSysconfigValue(db, name, target)
+	for name, target := range policy.policySettings() {
+		// The defaults are the built-in policy: everything disabled.
+		def := portalKeyDef{name: prefix + name, description: "Password policy setting " + name + "."}
+		var value string
+		switch t := target.(type) {
+		case *string:
+			value, def.xml = *t, `{"type":"string","default":""}`
+		case *int:
+			value, def.xml, def.defaultVal = strconv.Itoa(*t), `{"type":"integer","default":0}`, "0"
+		case *bool:
+			value, def.xml, def.defaultVal = boolToString(*t), `{"type":"boolean","default":false}`, "false"
+		}
+		if err := ensureSysconfigDefault(db, def.name, def, navigation, "Framework.xml", userID); err != nil {
+			return fmt.Errorf("sysconfig unavailable: %w", err)
+		}
+		if err := upsertSysconfigValue(db, def.name, value, userID); err != nil {
+			return fmt.Errorf("sysconfig unavailable: %w", err)
+		}
 	}
 
-	return policy, nil
+	return nil
 }
 
 // loadSysconfigValue loads a single sysconfig value into the target pointer.
@@ -249,6 +311,12 @@ func (p *PasswordPolicy) GetRequirements() []string {
 	if p.PasswordRegExp != "" {
 		requirements = append(requirements, "regexp")
 	}
+	if p.PasswordHistory > 0 {
+		requirements = append(requirements, "history")
+	}
+	if p.PasswordBreachCheck {
+		requirements = append(requirements, "breach_check")
+	}
 
 	return requirements
 }
@@ -259,5 +327,7 @@ func (p *PasswordPolicy) HasRequirements() bool {
 		p.PasswordMin2Lower2UpperCharacters ||
 		p.PasswordNeedDigit ||
 		p.PasswordMin2Characters ||
-		p.PasswordRegExp != ""
+		p.PasswordRegExp != "" ||
+		p.PasswordHistory > 0 ||
+		p.PasswordBreachCheck
 }
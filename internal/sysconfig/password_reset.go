@@ -0,0 +1,99 @@
+package sysconfig
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+)
+
+// PasswordResetConfig holds the forgot-password settings of agents and
+// customers.
+type PasswordResetConfig struct {
+	// Agents offers the forgot-password link on the agent login page; it
+	// also needs features.lost_password in the configuration file.
+	Agents bool `json:"agents"`
+
+	// Customers offers the forgot-password link on the customer portal.
+	Customers bool `json:"customers"`
+
+	// TokenValidMinutes is how long reset links stay valid.
+	TokenValidMinutes int `json:"token_valid_minutes"`
+
+	// MaxRequests reset links may be requested for one account within
+	// WindowMinutes; one more locks the account out of password resets for
+	// LockoutMinutes.
+	MaxRequests    int `json:"max_requests"`
+	WindowMinutes  int `json:"window_minutes"`
+	LockoutMinutes int `json:"lockout_minutes"`
+}
+
+// passwordResetKey is the sysconfig name holding the settings as JSON.
+const passwordResetKey = "Core::PasswordReset"
+
+// DefaultPasswordResetConfig returns the built-in settings: resets offered
+// to agents and customers, links valid for an hour, three requests an hour
+// before a one hour lockout.
+func DefaultPasswordResetConfig() PasswordResetConfig {
+	return PasswordResetConfig{
+		Agents:            true,
+		Customers:         true,
+		TokenValidMinutes: 60,
+		MaxRequests:       3,
+		WindowMinutes:     60,
+		LockoutMinutes:    60,
+	}
+}
+
+// LoadPasswordResetConfig loads the forgot-password settings from sysconfig.
+func LoadPasswordResetConfig(db *sql.DB) (PasswordResetConfig, error) {
+	cfg := DefaultPasswordResetConfig()
+	if db == nil {
+		return cfg, nil
+	}
+	raw, ok := sysconfigValue(db, passwordResetKey)
+	if !ok || raw == "" {
+		return cfg, nil
+	}
+	if err := json.Unmarshal([]byte(raw), &cfg); err != nil {
+		return DefaultPasswordResetConfig(), fmt.Errorf("invalid %s: %w", passwordResetKey, err)
+	}
+	def := DefaultPasswordResetConfig()
+	if cfg.TokenValidMinutes <= 0 {
+		cfg.TokenValidMinutes = def.TokenValidMinutes
+	}
+	if cfg.MaxRequests <= 0 {
+		cfg.MaxRequests = def.MaxRequests
+	}
+	if cfg.WindowMinutes <= 0 {
+		cfg.WindowMinutes = def.WindowMinutes
+	}
+	if cfg.LockoutMinutes <= 0 {
+		cfg.LockoutMinutes = def.LockoutMinutes
+	}
+	return cfg, nil
+}
+
+// SavePasswordResetConfig persists the forgot-password settings as a
+// sysconfig override.
+func SavePasswordResetConfig(db *sql.DB, cfg PasswordResetConfig, userID int) error {
+	if db == nil {
+		return fmt.Errorf("database connection unavailable")
+	}
+	value, err := json.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+	def := portalKeyDef{
+		name:        passwordResetKey,
+		description: "Forgot-password settings as JSON: who may reset, link lifetime, request limit and lockout; set through the password reset admin API.",
+		xml:         `{"type":"textarea","default":"{}"}`,
+		defaultVal:  `{}`,
+	}
+	if err := ensureSysconfigDefault(db, def.name, def, "Core::Auth", "Framework.xml", userID); err != nil {
+		return fmt.Errorf("sysconfig unavailable: %w", err)
+	}
+	if err := upsertSysconfigValue(db, def.name, string(value), userID); err != nil {
+		return fmt.Errorf("sysconfig unavailable: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+package sysconfig
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/mail"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Setting types understood by Schema. Unknown types are treated as strings.
+const (
+	TypeString   = "string"
+	TypeText     = "textarea"
+	TypeInteger  = "integer"
+	TypeBoolean  = "boolean"
+	TypeSelect   = "select"
+	TypeEmail    = "email"
+	TypeArray    = "array"
+	TypePassword = "password"
+)
+
+// Schema is the type information of a setting, stored as JSON in
+// sysconfig_default.xml_content_parsed, e.g.
+// {"type":"integer","default":0,"min":0,"max":100}.
+type Schema struct {
+	Type         string      `json:"type"`
+	Default      interface{} `json:"default,omitempty"`
+	Options      []Option    `json:"options,omitempty"`
+	Min          *int        `json:"min,omitempty"`
+	Max          *int        `json:"max,omitempty"`
+	Validation   string      `json:"validation,omitempty"`
+	DependsOn    string      `json:"depends_on,omitempty"`
+	DependsValue string      `json:"depends_value,omitempty"`
+}
+
+// ParseSchema parses the JSON schema of a setting. An empty string is a
+// plain string setting. Options may be given as objects with value and
+// label, or as bare strings.
+func ParseSchema(raw string) (Schema, error) {
+	var parsed struct {
+		Schema
+		Options []json.RawMessage `json:"options"`
+	}
+	if strings.TrimSpace(raw) == "" {
+		return Schema{Type: TypeString}, nil
+	}
+	if err := json.Unmarshal([]byte(raw), &parsed); err != nil {
+		return Schema{Type: TypeString}, fmt.Errorf("invalid JSON in xml_content_parsed: %w", err)
+	}
+	s := parsed.Schema
+	s.Options = nil
+	for _, rawOpt := range parsed.Options {
+		var opt Option
+		if err := json.Unmarshal(rawOpt, &opt); err != nil {
+			var value string
+			if json.Unmarshal(rawOpt, &value) != nil {
+				continue
+			}
+			opt = Option{Value: value, Label: value}
+		}
+		if opt.Label == "" {
+			opt.Label = opt.Value
+		}
+		s.Options = append(s.Options, opt)
+	}
+	if s.Type == "" {
+		s.Type = TypeString
+	}
+	if s.Validation != "" {
+		if _, err := regexp.Compile(s.Validation); err != nil {
+			return s, fmt.Errorf("invalid validation pattern: %w", err)
+		}
+	}
+	return s, nil
+}
+
+// Normalize returns value in its stored form: booleans become "true" or
+// "false", integers lose surrounding space and arrays become JSON.
+func (s Schema) Normalize(value string) string {
+	switch s.Type {
+	case TypeBoolean:
+		switch strings.ToLower(strings.TrimSpace(value)) {
+		case "true", "1", "yes", "on":
+			return "true"
+		case "false", "0", "no", "off", "":
+			return "false"
+		}
+	case TypeInteger:
+		return strings.TrimSpace(value)
+	case TypeArray:
+		if arr, ok := s.TypedValue(value).([]string); ok {
+			data, err := json.Marshal(arr)
+			if err == nil {
+				return string(data)
+			}
+		}
+	}
+	return value
+}
+
+// Validate checks a value, in stored form, against the schema.
+func (s Schema) Validate(value string) error {
+	switch s.Type {
+	case TypeInteger:
+		i, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("must be an integer")
+		}
+		if s.Min != nil && i < *s.Min {
+			return fmt.Errorf("must be at least %d", *s.Min)
+		}
+		if s.Max != nil && i > *s.Max {
+			return fmt.Errorf("must be at most %d", *s.Max)
+		}
+	case TypeBoolean:
+		if value != "true" && value != "false" {
+			return fmt.Errorf("must be true or false")
+		}
+	case TypeSelect:
+		if len(s.Options) > 0 {
+			for _, opt := range s.Options {
+				if opt.Value == value {
+					return nil
+				}
+			}
+			return fmt.Errorf("must be one of the allowed options")
+		}
+	case TypeEmail:
+		if value != "" {
+			if addr, err := mail.ParseAddress(value); err != nil || addr.Address != value {
+				return fmt.Errorf("must be a valid email address")
+			}
+		}
+	case TypeArray:
+		if strings.HasPrefix(strings.TrimSpace(value), "[") {
+			var arr []string
+			if err := json.Unmarshal([]byte(value), &arr); err != nil {
+				return fmt.Errorf("must be a JSON array of strings")
+			}
+		}
+	default:
+		if s.Min != nil && len([]rune(value)) < *s.Min {
+			return fmt.Errorf("must be at least %d characters", *s.Min)
+		}
+		if s.Max != nil && len([]rune(value)) > *s.Max {
+			return fmt.Errorf("must be at most %d characters", *s.Max)
+		}
+	}
+
+	if s.Validation != "" && s.Type != TypeBoolean {
+		re, err := regexp.Compile(s.Validation)
+		if err != nil {
+			return fmt.Errorf("has an invalid validation pattern")
+		}
+		if !re.MatchString(value) {
+			return fmt.Errorf("must match %s", s.Validation)
+		}
+	}
+	return nil
+}
+
+// TypedValue converts a stored value to the setting's Go type: bool, int,
+// []string or string.
+func (s Schema) TypedValue(value string) interface{} {
+	switch s.Type {
+	case TypeBoolean:
+		v := strings.ToLower(value)
+		return v == "true" || v == "1"
+	case TypeInteger:
+		if i, err := strconv.Atoi(strings.TrimSpace(value)); err == nil {
+			return i
+		}
+		return 0
+	case TypeArray:
+		var arr []string
+		if err := json.Unmarshal([]byte(value), &arr); err == nil {
+			return arr
+		}
+		if strings.TrimSpace(value) == "" {
+			return []string{}
+		}
+		parts := strings.Split(value, ",")
+		for i := range parts {
+			parts[i] = strings.TrimSpace(parts[i])
+		}
+		return parts
+	default:
+		return value
+	}
+}
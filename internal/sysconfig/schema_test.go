@@ -0,0 +1,59 @@
+package sysconfig
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSchema(t *testing.T) {
+	s, err := ParseSchema("")
+	require.NoError(t, err)
+	assert.Equal(t, TypeString, s.Type)
+
+	s, err = ParseSchema(`{"type":"select","options":["Low",{"value":"high","label":"High"}]}`)
+	require.NoError(t, err)
+	assert.Equal(t, []Option{{Value: "Low", Label: "Low"}, {Value: "high", Label: "High"}}, s.Options)
+
+	s, err = ParseSchema(`{"type":"integer","min":1,"max":5}`)
+	require.NoError(t, err)
+	require.NotNil(t, s.Min)
+	assert.Equal(t, 5, *s.Max)
+
+	_, err = ParseSchema(`{"type":`)
+	assert.Error(t, err)
+	_, err = ParseSchema(`{"validation":"["}`)
+	assert.Error(t, err)
+}
+
+func TestSchemaNormalizeAndValidate(t *testing.T) {
+	one, ten := 1, 10
+	for _, tc := range []struct {
+		name   string
+		schema Schema
+		input  string
+		stored string
+		valid  bool
+	}{
+		{"bool on", Schema{Type: TypeBoolean}, "on", "true", true},
+		{"bool garbage", Schema{Type: TypeBoolean}, "maybe", "maybe", false},
+		{"int in range", Schema{Type: TypeInteger, Min: &one, Max: &ten}, " 7 ", "7", true},
+		{"int too big", Schema{Type: TypeInteger, Min: &one, Max: &ten}, "11", "11", false},
+		{"int not a number", Schema{Type: TypeInteger}, "7x", "7x", false},
+		{"select option", Schema{Type: TypeSelect, Options: []Option{{Value: "a"}}}, "a", "a", true},
+		{"select other", Schema{Type: TypeSelect, Options: []Option{{Value: "a"}}}, "b", "b", false},
+		{"email", Schema{Type: TypeEmail}, "otrs@example.com", "otrs@example.com", true},
+		{"email with name", Schema{Type: TypeEmail}, "OTRS <otrs@example.com>", "OTRS <otrs@example.com>", false},
+		{"array from list", Schema{Type: TypeArray}, "a, b", `["a","b"]`, true},
+		{"string too long", Schema{Type: TypeString, Max: &one}, "ab", "ab", false},
+		{"pattern", Schema{Type: TypeString, Validation: `^[A-Z]+$`}, "ABC", "ABC", true},
+		{"pattern mismatch", Schema{Type: TypeString, Validation: `^[A-Z]+$`}, "abc", "abc", false},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			stored := tc.schema.Normalize(tc.input)
+			assert.Equal(t, tc.stored, stored)
+			assert.Equal(t, tc.valid, tc.schema.Validate(stored) == nil)
+		})
+	}
+}
@@ -138,61 +138,30 @@ func (m *Manager) parseSettingConfig(setting *Setting) error {
 		return nil
 	}
 
-	var config map[string]interface{}
-	if err := json.Unmarshal([]byte(setting.XMLContentParsed), &config); err != nil {
-		return fmt.Errorf("invalid JSON in xml_content_parsed: %w", err)
-	}
-
-	// Extract type information
-	if t, ok := config["type"].(string); ok {
-		setting.Type = t
-	}
-
-	// Extract default value
-	if def, ok := config["default"]; ok {
-		setting.Default = def
-	}
-
-	// Extract validation rules
-	if val, ok := config["validation"].(string); ok {
-		setting.Validation = val
-	}
-
-	// Extract min/max for integer fields
-	if min, ok := config["min"].(float64); ok {
-		minInt := int(min)
-		setting.Min = &minInt
-	}
-	if max, ok := config["max"].(float64); ok {
-		maxInt := int(max)
-		setting.Max = &maxInt
-	}
-
-	// Extract dependencies
-	if dep, ok := config["depends_on"].(string); ok {
-		setting.DependsOn = dep
-	}
-	if depVal, ok := config["depends_value"].(string); ok {
-		setting.DependsValue = depVal
-	}
+	schema, err := ParseSchema(setting.XMLContentParsed)
+	if err != nil {
+		return err
+	}
+	setting.Type = schema.Type
+	setting.Default = schema.Default
+	setting.Validation = schema.Validation
+	setting.Min = schema.Min
+	setting.Max = schema.Max
+	setting.DependsOn = schema.DependsOn
+	setting.DependsValue = schema.DependsValue
+	setting.Options = schema.Options
+	return nil
+}
 
-	// Extract options for select fields
-	if opts, ok := config["options"].([]interface{}); ok {
-		for _, opt := range opts {
-			if optMap, ok := opt.(map[string]interface{}); ok {
-				option := Option{}
-				if val, ok := optMap["value"].(string); ok {
-					option.Value = val
-				}
-				if label, ok := optMap["label"].(string); ok {
-					option.Label = label
-				}
-				setting.Options = append(setting.Options, option)
-			}
-		}
+// schema returns the type information of a loaded setting.
+func (setting *Setting) schema() Schema {
+	return Schema{
+		Type:       setting.Type,
+		Options:    setting.Options,
+		Min:        setting.Min,
+		Max:        setting.Max,
+		Validation: setting.Validation,
 	}
-
-	return nil
 }
 
 // Get retrieves a configuration value.
@@ -284,23 +253,7 @@ func (m *Manager) GetArray(name string) []string {
 
 // parseValue converts string values to appropriate types.
 func (m *Manager) parseValue(value, valueType string) interface{} {
-	switch valueType {
-	case "boolean":
-		return strings.ToLower(value) == "true"
-	case "integer":
-		if i, err := strconv.Atoi(value); err == nil {
-			return i
-		}
-		return 0
-	case "array":
-		var arr []string
-		if err := json.Unmarshal([]byte(value), &arr); err == nil {
-			return arr
-		}
-		return strings.Split(value, ",")
-	default:
-		return value
-	}
+	return Schema{Type: valueType}.TypedValue(value)
 }
 
 // getModifiedValue checks for user-modified values.
@@ -371,43 +324,7 @@ func (m *Manager) Set(name, value string, userID int) error {
 
 // validateValue validates a configuration value.
 func (m *Manager) validateValue(setting *Setting, value string) error {
-	switch setting.Type {
-	case "integer":
-		i, err := strconv.Atoi(value)
-		if err != nil {
-			return fmt.Errorf("must be an integer")
-		}
-		if setting.Min != nil && i < *setting.Min {
-			return fmt.Errorf("must be at least %d", *setting.Min)
-		}
-		if setting.Max != nil && i > *setting.Max {
-			return fmt.Errorf("must be at most %d", *setting.Max)
-		}
-	case "boolean":
-		if value != "true" && value != "false" {
-			return fmt.Errorf("must be true or false")
-		}
-	case "select":
-		if len(setting.Options) > 0 {
-			for _, opt := range setting.Options {
-				if opt.Value == value {
-					return nil
-				}
-			}
-			return fmt.Errorf("must be one of the allowed options")
-		}
-	case "email":
-		if !strings.Contains(value, "@") {
-			return fmt.Errorf("must be a valid email address")
-		}
-	}
-
-	// Additional validation using regex
-	if setting.Validation != "" {
-		// TODO: Add regex validation
-	}
-
-	return nil
+	return setting.schema().Validate(value)
 }
 
 // Deploy generates configuration files from database settings.
@@ -0,0 +1,71 @@
+package sysconfig
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// TelemetrySettings control the opt-in anonymous usage telemetry.
+type TelemetrySettings struct {
+	// Enabled switches counting on; it is off until an admin opts in.
+	Enabled bool `json:"enabled"`
+
+	// Submit sends a daily report to Endpoint; without it usage is only
+	// shown locally.
+	Submit   bool   `json:"submit"`
+	Endpoint string `json:"endpoint,omitempty"`
+
+	// InstallID is a random ID identifying reports of this installation.
+	InstallID string `json:"install_id,omitempty"`
+
+	// LastSubmitted and LastError record the outcome of the last report.
+	LastSubmitted *time.Time `json:"last_submitted,omitempty"`
+	LastError     string     `json:"last_error,omitempty"`
+}
+
+// telemetryKey is the sysconfig name holding the settings as JSON.
+const telemetryKey = "Core::Telemetry"
+
+// LoadTelemetrySettings loads the telemetry settings from sysconfig. Without
+// any, telemetry is off.
+func LoadTelemetrySettings(db *sql.DB) (*TelemetrySettings, error) {
+	settings := &TelemetrySettings{}
+	if db == nil {
+		return settings, nil
+	}
+	raw, ok := sysconfigValue(db, telemetryKey)
+	if !ok || raw == "" {
+		return settings, nil
+	}
+	if err := json.Unmarshal([]byte(raw), settings); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", telemetryKey, err)
+	}
+	return settings, nil
+}
+
+// SaveTelemetrySettings persists the telemetry settings as a sysconfig
+// override.
+func SaveTelemetrySettings(db *sql.DB, settings *TelemetrySettings, userID int) error {
+	if db == nil {
+		return fmt.Errorf("database connection unavailable")
+	}
+	value, err := json.Marshal(settings)
+	if err != nil {
+		return err
+	}
+	def := portalKeyDef{
+		name:        telemetryKey,
+		description: "Anonymous usage telemetry as JSON with enabled, submit and endpoint; managed under Admin > System Insights.",
+		xml:         `{"type":"textarea","default":"{}"}`,
+		defaultVal:  "{}",
+	}
+	if err := ensureSysconfigDefault(db, def.name, def, "Core", "Framework.xml", userID); err != nil {
+		return fmt.Errorf("sysconfig unavailable: %w", err)
+	}
+	if err := upsertSysconfigValue(db, def.name, string(value), userID); err != nil {
+		return fmt.Errorf("sysconfig unavailable: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+// Package telemetry counts anonymous usage of the running instance: how
+// often each route is requested and fails, and how often and how long each
+// plugin function runs.
+//
+// Only route templates such as "GET /api/v1/tickets/:id" and plugin and
+// function names are recorded, never paths, parameters, users or addresses.
+// Counting is off until an admin opts in; while off the Record functions
+// return at once. Counters live in memory until Drain hands them to the
+// caller for storing.
+package telemetry
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Kinds of usage counted.
+const (
+	KindRoute  = "route"
+	KindPlugin = "plugin"
+)
+
+// Counter is the usage of one route or plugin function.
+type Counter struct {
+	Calls      int64 `json:"calls"`
+	Errors     int64 `json:"errors"`
+	DurationMs int64 `json:"duration_ms"`
+}
+
+// Key identifies what a counter counts.
+type Key struct {
+	Kind string
+	Name string
+}
+
+var (
+	enabled  atomic.Bool
+	mu       sync.Mutex
+	counters = make(map[Key]*Counter)
+)
+
+// SetEnabled switches counting on or off. Switching it off discards what
+// was counted but not drained yet.
+func SetEnabled(on bool) {
+	enabled.Store(on)
+	if !on {
+		Drain()
+	}
+}
+
+// Enabled reports whether usage is being counted.
+func Enabled() bool {
+	return enabled.Load()
+}
+
+// RecordRoute counts a request to a route template. Server errors (5xx)
+// count as errors.
+func RecordRoute(method, route string, status int, d time.Duration) {
+	if !enabled.Load() || route == "" {
+		return
+	}
+	record(Key{Kind: KindRoute, Name: method + " " + route}, status >= 500, d)
+}
+
+// RecordPluginCall counts a call to a plugin function.
+func RecordPluginCall(plugin, fn string, d time.Duration, err error) {
+	if !enabled.Load() || plugin == "" {
+		return
+	}
+	record(Key{Kind: KindPlugin, Name: plugin + "." + fn}, err != nil, d)
+}
+
+func record(k Key, failed bool, d time.Duration) {
+	mu.Lock()
+	defer mu.Unlock()
+	c := counters[k]
+	if c == nil {
+		c = &Counter{}
+		counters[k] = c
+	}
+	c.Calls++
+	if failed {
+		c.Errors++
+	}
+	c.DurationMs += d.Milliseconds()
+}
+
+// Drain returns the counters collected since the last drain and resets
+// them.
+func Drain() map[Key]Counter {
+	mu.Lock()
+	defer mu.Unlock()
+	drained := make(map[Key]Counter, len(counters))
+	for k, c := range counters {
+		drained[k] = *c
+	}
+	counters = make(map[Key]*Counter)
+	return drained
+}
@@ -0,0 +1,34 @@
+package telemetry
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecord(t *testing.T) {
+	t.Cleanup(func() { SetEnabled(false) })
+
+	SetEnabled(false)
+	RecordRoute("GET", "/api/v1/tickets/:id", 200, time.Millisecond)
+	assert.Empty(t, Drain(), "nothing is counted until enabled")
+
+	SetEnabled(true)
+	RecordRoute("GET", "/api/v1/tickets/:id", 200, 20*time.Millisecond)
+	RecordRoute("GET", "/api/v1/tickets/:id", 503, 10*time.Millisecond)
+	RecordRoute("GET", "/api/v1/tickets/:id", 404, 0)
+	RecordRoute("GET", "", 404, 0)
+	RecordPluginCall("jira-sync", "sync", 150*time.Millisecond, errors.New("timeout"))
+
+	got := Drain()
+	assert.Equal(t, Counter{Calls: 3, Errors: 1, DurationMs: 30}, got[Key{KindRoute, "GET /api/v1/tickets/:id"}])
+	assert.Equal(t, Counter{Calls: 1, Errors: 1, DurationMs: 150}, got[Key{KindPlugin, "jira-sync.sync"}])
+	assert.Len(t, got, 2, "unmatched routes are not counted")
+	assert.Empty(t, Drain())
+
+	RecordPluginCall("jira-sync", "sync", time.Millisecond, nil)
+	SetEnabled(false)
+	assert.Empty(t, Drain(), "switching off discards pending counts")
+}
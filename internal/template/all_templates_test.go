@@ -10,6 +10,9 @@ import (
 
 	"github.com/flosch/pongo2/v6"
 	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/jobqueue"
+	"github.com/goatkit/goatflow/internal/models"
 )
 
 // Common context builders for reusable test data
@@ -64,6 +67,24 @@ func TestLoginFormAction(t *testing.T) {
 	asserter.Contains("hx-boost=\"true\"")
 }
 
+func TestLoginSSOButtons(t *testing.T) {
+	helper := NewTemplateTestHelper(t)
+
+	for _, page := range []string{"pages/login.pongo2", "pages/customer/login.pongo2"} {
+		ctx := baseContext()
+		html, err := helper.RenderTemplate(page, ctx)
+		require.NoError(t, err)
+		NewHTMLAsserter(t, html).NotContains("/auth/sso/")
+
+		ctx["SSOProviders"] = []map[string]interface{}{{"ID": 3, "Name": "Company Login", "URL": "/auth/sso/3/login"}}
+		html, err = helper.RenderTemplate(page, ctx)
+		require.NoError(t, err)
+		asserter := NewHTMLAsserter(t, html)
+		asserter.Contains(`href="/auth/sso/3/login"`)
+		asserter.Contains("Company Login")
+	}
+}
+
 func TestCustomerLoginFormAction(t *testing.T) {
 	helper := NewTemplateTestHelper(t)
 	ctx := baseContext()
@@ -76,6 +97,27 @@ func TestCustomerLoginFormAction(t *testing.T) {
 	asserter.HasFormAction("/api/auth/customer/login")
 }
 
+func TestCustomerLoginPortalDomain(t *testing.T) {
+	helper := NewTemplateTestHelper(t)
+	ctx := baseContext()
+	ctx["Portal"] = map[string]interface{}{
+		"Domain": "support.acme.example.com", "Title": "Acme Support",
+		"Theme": "seventies-vibes", "LogoURL": "https://cdn.acme.test/logo.svg",
+	}
+	ctx["PasswordLoginDisabled"] = true
+	ctx["SSOProviders"] = []map[string]interface{}{{"ID": 3, "Name": "Acme Okta", "URL": "/auth/sso/3/login"}}
+
+	html, err := helper.RenderTemplate("pages/customer/login.pongo2", ctx)
+	require.NoError(t, err)
+
+	asserter := NewHTMLAsserter(t, html)
+	asserter.Contains("Acme Support")
+	asserter.Contains(`src="https://cdn.acme.test/logo.svg"`)
+	asserter.Contains(`var theme = 'seventies-vibes';`)
+	asserter.Contains(`href="/auth/sso/3/login"`)
+	asserter.NotContains(`action="/api/auth/customer/login"`)
+}
+
 func TestRegisterFormAction(t *testing.T) {
 	helper := NewTemplateTestHelper(t)
 	ctx := baseContext()
@@ -167,6 +209,112 @@ func TestCustomerTicketReplyFormAction(t *testing.T) {
 	asserter.HasFormAction("/customer/tickets/456/reply")
 }
 
+func TestCustomerSurveyForm(t *testing.T) {
+	helper := NewTemplateTestHelper(t)
+	ctx := customerContext()
+	ctx["State"] = "form"
+	ctx["Survey"] = map[string]interface{}{"Name": "Support survey", "Description": "Tell us how we did"}
+	ctx["TicketNumber"] = "2025010112345679"
+	ctx["Token"] = "c3VydmV5LXRva2Vu"
+	ctx["Questions"] = []map[string]interface{}{
+		{"ID": "q1", "Label": "How satisfied are you?", "Type": "rating", "Required": true, "Choices": []string{"1", "2", "3", "4", "5"}},
+		{"ID": "q2", "Label": "Anything else?", "Type": "text"},
+	}
+
+	html, err := helper.RenderTemplate("pages/customer/survey.pongo2", ctx)
+	require.NoError(t, err)
+
+	// The form posts back to the survey's own token URL
+	asserter := NewHTMLAsserter(t, html)
+	asserter.HasFormAction("/customer/survey/c3VydmV5LXRva2Vu")
+	asserter.Contains(`method="POST"`)
+	asserter.Contains(`name="q1"`)
+	asserter.Contains(`name="q2"`)
+}
+
+func TestCustomerRegisterForm(t *testing.T) {
+	helper := NewTemplateTestHelper(t)
+	ctx := customerContext()
+	ctx["State"] = "form"
+	ctx["Captcha"] = map[string]interface{}{"Provider": "hcaptcha", "SiteKey": "site-key-1"}
+	ctx["Input"] = map[string]interface{}{"Email": "jane@example.com", "FirstName": "Jane", "LastName": "Doe"}
+
+	html, err := helper.RenderTemplate("pages/customer/register.pongo2", ctx)
+	require.NoError(t, err)
+
+	asserter := NewHTMLAsserter(t, html)
+	asserter.HasFormAction("/customer/register")
+	asserter.Contains(`value="jane@example.com"`)
+	asserter.Contains(`class="h-captcha" data-sitekey="site-key-1"`)
+	asserter.NotContains("cf-turnstile")
+}
+
+func TestCustomerLoginRegistrationLink(t *testing.T) {
+	helper := NewTemplateTestHelper(t)
+	ctx := baseContext()
+
+	html, err := helper.RenderTemplate("pages/customer/login.pongo2", ctx)
+	require.NoError(t, err)
+	NewHTMLAsserter(t, html).NotContains(`href="/customer/register"`)
+
+	ctx["AllowRegistration"] = true
+	html, err = helper.RenderTemplate("pages/customer/login.pongo2", ctx)
+	require.NoError(t, err)
+	NewHTMLAsserter(t, html).Contains(`href="/customer/register"`)
+}
+
+func TestPasswordResetForms(t *testing.T) {
+	helper := NewTemplateTestHelper(t)
+
+	ctx := baseContext()
+	ctx["State"] = "request"
+	ctx["BasePath"] = "/customer"
+	html, err := helper.RenderTemplate("pages/password_reset.pongo2", ctx)
+	require.NoError(t, err)
+	asserter := NewHTMLAsserter(t, html)
+	asserter.HasFormAction("/customer/forgot-password")
+	asserter.Contains(`href="/customer/login"`)
+
+	ctx = baseContext()
+	ctx["State"] = "form"
+	ctx["BasePath"] = ""
+	html, err = helper.RenderTemplate("pages/password_reset.pongo2", ctx)
+	require.NoError(t, err)
+	asserter = NewHTMLAsserter(t, html)
+	asserter.Contains(`name="password_confirm"`)
+	asserter.Contains(`href="/login"`)
+}
+
+func TestCSRFTokenInLayoutsAndForms(t *testing.T) {
+	helper := NewTemplateTestHelper(t)
+
+	for _, name := range []string{"pages/password_reset.pongo2", "pages/admin/dynamic_field_export.pongo2"} {
+		ctx := baseContext()
+		ctx["State"] = "request"
+		ctx["CSRFToken"] = "tok-123"
+		html, err := helper.RenderTemplate(name, ctx)
+		require.NoError(t, err, name)
+		asserter := NewHTMLAsserter(t, html)
+		asserter.Contains(`<meta name="csrf-token" content="tok-123">`)
+		asserter.Contains(`<script src="/static/js/csrf.js"></script>`)
+		asserter.Contains(`<input type="hidden" name="csrf_token" value="tok-123">`)
+	}
+}
+
+func TestCustomerLoginForgotPasswordLink(t *testing.T) {
+	helper := NewTemplateTestHelper(t)
+	ctx := baseContext()
+
+	html, err := helper.RenderTemplate("pages/customer/login.pongo2", ctx)
+	require.NoError(t, err)
+	NewHTMLAsserter(t, html).NotContains(`href="/customer/forgot-password"`)
+
+	ctx["AllowLostPassword"] = true
+	html, err = helper.RenderTemplate("pages/customer/login.pongo2", ctx)
+	require.NoError(t, err)
+	NewHTMLAsserter(t, html).Contains(`href="/customer/forgot-password"`)
+}
+
 // =============================================================================
 // ADMIN TEMPLATES
 // =============================================================================
@@ -187,6 +335,24 @@ func TestAdminCustomerPortalSettingsForm(t *testing.T) {
 	asserter.HasHTMXPost("/admin/customer/portal/settings")
 }
 
+func TestAdminAppearanceForm(t *testing.T) {
+	helper := NewTemplateTestHelper(t)
+	ctx := adminContext()
+	ctx["Tenants"] = []map[string]interface{}{{"CustomerID": "ACME", "Name": "Acme Corp"}}
+	ctx["Defaults"] = map[string]interface{}{"ProductName": "GoatFlow"}
+
+	html, err := helper.RenderTemplate("pages/admin/appearance.pongo2", ctx)
+	require.NoError(t, err)
+
+	// Settings save through fetch(), so the form has no action; the tenant
+	// picker selects which branding is edited
+	asserter := NewHTMLAsserter(t, html)
+	asserter.Contains(`id="appearance-form"`)
+	asserter.Contains(`<option value="ACME">Acme Corp (ACME)</option>`)
+	asserter.Contains(`placeholder="GoatFlow"`)
+	asserter.Contains(`name="email_footer"`)
+}
+
 func TestAdminCustomerCompanyFormCreate(t *testing.T) {
 	helper := NewTemplateTestHelper(t)
 	ctx := baseContext()
@@ -248,6 +414,180 @@ func TestAdminEmailQueueRetryForms(t *testing.T) {
 	asserter.Contains("hx-post=\"/admin/email-queue/delete/")
 }
 
+func TestAdminCustomerImportForms(t *testing.T) {
+	helper := NewTemplateTestHelper(t)
+
+	ctx := baseContext()
+	ctx["ShowUpload"] = true
+	ctx["Kind"] = "companies"
+	html, err := helper.RenderTemplate("pages/admin/customer_import.pongo2", ctx)
+	require.NoError(t, err)
+	asserter := NewHTMLAsserter(t, html)
+	asserter.HasFormAction("/admin/customer-import/preview")
+	asserter.Contains(`<option value="companies" selected`)
+
+	row := map[string]interface{}{"Line": 2, "Key": "jdoe", "Action": "error", "Errors": []string{"Email is required"}}
+	ctx = baseContext()
+	ctx["ShowPreview"] = true
+	ctx["Kind"] = "users"
+	ctx["Preview"] = map[string]interface{}{
+		"Fields":  []map[string]interface{}{{"Name": "login", "Label": "Login", "Required": true}},
+		"Creates": 0, "Updates": 0, "Skips": 0, "Invalid": 1,
+	}
+	ctx["Columns"] = []map[string]interface{}{{"Header": "UserLogin", "Field": "login"}}
+	ctx["Rows"] = []map[string]interface{}{row}
+	html, err = helper.RenderTemplate("pages/admin/customer_import.pongo2", ctx)
+	require.NoError(t, err)
+	asserter = NewHTMLAsserter(t, html)
+	asserter.HasFormAction("/admin/customer-import/confirm")
+	asserter.Contains(`formaction="/admin/customer-import/preview"`)
+	asserter.Contains(`<option value="login" selected`)
+	asserter.Contains("Email is required")
+
+	ctx = baseContext()
+	ctx["ShowResults"] = true
+	ctx["Kind"] = "users"
+	ctx["Result"] = map[string]interface{}{"Created": 1, "Rows": []map[string]interface{}{row}}
+	ctx["ReportData"] = "bGluZQo="
+	html, err = helper.RenderTemplate("pages/admin/customer_import.pongo2", ctx)
+	require.NoError(t, err)
+	NewHTMLAsserter(t, html).Contains(`href="data:text/csv;charset=utf-8;base64,bGluZQo="`)
+}
+
+func TestAdminSysconfigEditor(t *testing.T) {
+	helper := NewTemplateTestHelper(t)
+	minTimeout := 300
+	deployed := "57600"
+	ctx := baseContext()
+	ctx["Navigations"] = []string{"Core", "Core::Session"}
+	ctx["Navigation"] = "Core::Session"
+	ctx["Settings"] = []map[string]interface{}{
+		{"Name": "SessionMaxTime", "Type": "integer", "Value": "3600", "Default": "57600", "Min": &minTimeout, "Modified": true},
+		{"Name": "Ticket::Watcher", "Type": "boolean", "Value": "true"},
+		{"Name": "DefaultTheme", "Type": "select", "Value": "Dark", "Options": []map[string]interface{}{
+			{"Value": "Standard", "Label": "Standard"}, {"Value": "Dark", "Label": "Dark"}}},
+		{"Name": "Ticket::Languages", "Type": "array", "TypedValue": []string{"en", "de"}},
+		{"Name": "SendmailModule::AuthPassword", "Type": "password", "Modified": true, "Secret": true},
+		{"Name": "SystemID", "Type": "integer", "Value": "10", "ReadOnly": true},
+	}
+	ctx["Diff"] = []map[string]interface{}{
+		{"Name": "SessionMaxTime", "Default": "57600", "Value": "3600", "Deployed": &deployed, "Pending": true},
+	}
+	ctx["PendingCount"] = 1
+	ctx["Deployments"] = []map[string]interface{}{
+		{"ID": 2, "Comments": "initial", "CreateTime": time.Now(), "SettingCount": 12},
+		{"ID": 1, "CreateTime": time.Now()},
+	}
+
+	html, err := helper.RenderTemplate("pages/admin/sysconfig.pongo2", ctx)
+	require.NoError(t, err)
+
+	asserter := NewHTMLAsserter(t, html)
+	asserter.HasFormAction("/admin/settings")
+	asserter.Contains(`<option value="Core::Session" selected`)
+	asserter.Contains(`value="3600"`)
+	asserter.Contains(`min="300"`)
+	asserter.Contains(`<option value="Dark" selected`)
+	asserter.Contains("en\nde</textarea>")
+	asserter.Contains(`data-reset="SessionMaxTime"`)
+	asserter.Contains("57600 → 3600")
+	asserter.Contains(`data-deployment="2"`)
+	asserter.NotContains(`data-deployment="1"`)
+	asserter.Contains("/api/v1/admin/config")
+	asserter.NotContains("hx-post")
+}
+
+func TestAdminSysconfigHistory(t *testing.T) {
+	helper := NewTemplateTestHelper(t)
+	before, after := "57600", "3600"
+	ctx := baseContext()
+	ctx["Deployments"] = []map[string]interface{}{
+		{"ID": 3, "Comments": "shorter sessions", "CreateTime": time.Now(), "SettingCount": 12},
+		{"ID": 2, "CreateTime": time.Now(), "SettingCount": 12},
+		{"ID": 1, "CreateTime": time.Now()},
+	}
+	ctx["SelectedID"] = 3
+	ctx["Diff"] = map[string]interface{}{
+		"Deployment": map[string]interface{}{"ID": 3, "Comments": "shorter sessions", "CreateTime": time.Now()},
+		"Previous":   map[string]interface{}{"ID": 2},
+		"Changes": []map[string]interface{}{
+			{"Name": "SessionMaxTime", "Change": "changed", "Before": &before, "After": &after},
+			{"Name": "Ticket::Hook", "Change": "added", "After": &after},
+		},
+	}
+
+	html, err := helper.RenderTemplate("pages/admin/sysconfig_history.pongo2", ctx)
+	require.NoError(t, err)
+
+	asserter := NewHTMLAsserter(t, html)
+	asserter.Contains(`href="/admin/settings/history?id=1"`)
+	asserter.Contains(`aria-current="page"`)
+	asserter.Contains(">57600</td>")
+	asserter.Contains(">3600</td>")
+	asserter.Contains(`data-deployment="3"`)
+	asserter.Contains("/rollback")
+	asserter.NotContains("hx-post")
+
+	// An OTRS deployment has no readable snapshot and offers no rollback.
+	ctx["SelectedID"] = 1
+	ctx["Diff"] = nil
+	html, err = helper.RenderTemplate("pages/admin/sysconfig_history.pongo2", ctx)
+	require.NoError(t, err)
+	NewHTMLAsserter(t, html).NotContains(`data-deployment=`)
+}
+
+func TestAdminMaintenance(t *testing.T) {
+	helper := NewTemplateTestHelper(t)
+	ctx := baseContext()
+	start := time.Now().Add(-time.Hour)
+	ctx["MaintenanceStatus"] = &models.MaintenanceStatus{
+		Maintenance: true,
+		Active:      &models.MaintenanceWindow{ID: 4, Start: start, Stop: start.Add(2 * time.Hour), Message: "Upgrading"},
+	}
+	ctx["Windows"] = []*models.SystemMaintenance{
+		{ID: 4, StartDate: start.Unix(), StopDate: start.Add(2 * time.Hour).Unix(), Comments: "Database upgrade", ValidID: 1},
+	}
+	ctx["Announcements"] = []*models.SystemAnnouncement{
+		{ID: 7, Message: "Mail is delayed", Audience: models.AnnouncementAudienceCustomer, Severity: models.AnnouncementSeverityCritical, StartTime: &start, ValidID: 1},
+	}
+
+	html, err := helper.RenderTemplate("pages/admin/maintenance.pongo2", ctx)
+	require.NoError(t, err)
+
+	asserter := NewHTMLAsserter(t, html)
+	asserter.Contains("admin.maintenance.login_blocked")
+	asserter.Contains(`href="/admin/system-maintenance/4/edit"`)
+	asserter.Contains("Database upgrade")
+	asserter.Contains(`data-edit-announcement="7"`)
+	asserter.Contains("admin.maintenance.severity_critical")
+	asserter.Contains("admin.maintenance.audience_customer")
+	asserter.Contains("/api/v1/admin/announcements")
+	asserter.NotContains("hx-post")
+}
+
+func TestAdminJobs(t *testing.T) {
+	helper := NewTemplateTestHelper(t)
+	ctx := baseContext()
+	ctx["Enabled"] = true
+	ctx["Queues"] = []jobqueue.QueueStats{{Queue: "mail", Pending: 3, Scheduled: 1, Failed: 2}}
+	ctx["FailedJobs"] = []*jobqueue.Job{
+		{ID: "a1b2", Queue: "mail", Type: "mail.send", Attempts: 5, MaxAttempts: 5, LastError: "smtp: connection refused", UpdatedAt: time.Now()},
+	}
+	ctx["QueueFilter"] = ""
+
+	html, err := helper.RenderTemplate("pages/admin/jobs.pongo2", ctx)
+	require.NoError(t, err)
+
+	asserter := NewHTMLAsserter(t, html)
+	asserter.Contains(`href="/admin/jobs?queue=mail"`)
+	asserter.Contains(`data-retry-job="a1b2"`)
+	asserter.Contains(`data-delete-job="a1b2"`)
+	asserter.Contains("smtp: connection refused")
+	asserter.Contains("/admin/api/jobs/")
+	asserter.NotContains("admin.jobs.disabled")
+	asserter.NotContains("hx-post")
+}
+
 // =============================================================================
 // SEARCH/FILTER FORMS (GET actions - verify they don't accidentally use POST)
 // =============================================================================
@@ -423,6 +763,7 @@ var testedFormTemplates = map[string]bool{
 	"pages/login.pongo2":          true,
 	"pages/register.pongo2":       true,
 	"pages/customer/login.pongo2": true,
+	"pages/password_reset.pongo2":  true,
 
 	// Tickets
 	"pages/tickets/new.pongo2":          true,
@@ -430,6 +771,8 @@ var testedFormTemplates = map[string]bool{
 	"pages/ticket_detail.pongo2":        true,
 	"pages/customer/new_ticket.pongo2":  true,
 	"pages/customer/ticket_view.pongo2": true,
+	"pages/customer/survey.pongo2":      true,
+	"pages/customer/register.pongo2":    true,
 
 	// Admin
 	"pages/admin/attachment.pongo2":               true,
@@ -478,6 +821,19 @@ var testedFormTemplates = map[string]bool{
 	"pages/admin/dynamic_field_export.pongo2": true,
 	"pages/admin/dynamic_field_import.pongo2": true,
 
+	// Customer user and company import wizard
+	"pages/admin/customer_import.pongo2": true,
+
+	// System configuration editor (filter form; saves through fetch())
+	"pages/admin/sysconfig.pongo2":         true,
+	"pages/admin/sysconfig_history.pongo2": true,
+
+	// Maintenance overview (announcements save through fetch())
+	"pages/admin/maintenance.pongo2": true,
+
+	// Background jobs (retry and delete through fetch())
+	"pages/admin/jobs.pongo2": true,
+
 	// Webservices
 	"pages/admin/webservices.pongo2":        true,
 	"pages/admin/webservice_form.pongo2":    true,
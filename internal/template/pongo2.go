@@ -1,10 +1,12 @@
 package template
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/flosch/pongo2/v6"
 	"github.com/gin-gonic/gin"
@@ -286,4 +288,10 @@ func (r *Pongo2Renderer) addMaintenanceContext(ctx pongo2.Context) {
 	if coming, err := repo.IsComing(upcomingMinutes); err == nil && coming != nil {
 		ctx["MaintenanceComing"] = coming
 	}
+
+	// Announcements; the layout shows those meant for the agent or the
+	// customer interface
+	if announcements, err := repository.NewSystemAnnouncementRepository(db).ListActive(context.Background(), time.Now()); err == nil && len(announcements) > 0 {
+		ctx["SystemAnnouncements"] = announcements
+	}
 }
@@ -89,15 +89,27 @@ var AllPageTemplates = map[string]bool{
 	"pages/admin/customer_user_group_by_group.pongo2": true,
 	"pages/admin/dynamic_field_export.pongo2":        true,
 	"pages/admin/dynamic_field_import.pongo2":        true,
+	"pages/admin/customer_import.pongo2":             true,
 	"pages/admin/webservices.pongo2":                 true,
 	"pages/admin/webservice_form.pongo2":             true,
 	"pages/admin/webservice_history.pongo2":          true,
+	"pages/admin/sso_providers.pongo2":               true,
+	"pages/admin/sysconfig.pongo2":                   true,
+	"pages/admin/sysconfig_history.pongo2":           true,
+	"pages/admin/maintenance.pongo2":                 true,
+	"pages/admin/jobs.pongo2":                        true,
+	"pages/admin/sso_provider_form.pongo2":           true,
 	"pages/admin/sessions.pongo2":                     true,
 	"pages/admin/system_maintenance.pongo2":           true,
 	"pages/admin/system_maintenance_form.pongo2":      true,
 	"pages/admin/ticket_attribute_relations.pongo2":   true,
 	"pages/admin/plugins.pongo2":                      true,
 	"pages/admin/plugin_logs.pongo2":                  true,
+	"pages/admin/appearance.pongo2":                   true,
+	"pages/admin/feature_flags.pongo2":                true,
+	"pages/admin/system_insights.pongo2":               true,
+	"pages/admin/helpdesk_imports.pongo2":              true,
+	"pages/admin/plugin_page.pongo2":                  true,
 
 	// Agent templates
 	"pages/agent/queues.pongo2":      true,
@@ -116,6 +128,8 @@ var AllPageTemplates = map[string]bool{
 	"pages/customer/new_ticket.pongo2":     true,
 	"pages/customer/password_form.pongo2":  true,
 	"pages/customer/profile.pongo2":        true,
+	"pages/customer/register.pongo2":       true,
+	"pages/customer/survey.pongo2":         true,
 	"pages/customer/ticket_view.pongo2":    true,
 	"pages/customer/tickets.pongo2":        true,
 
@@ -142,6 +156,7 @@ var AllPageTemplates = map[string]bool{
 	"pages/login.pongo2":              true,
 	"pages/login_2fa.pongo2":          true,
 	"pages/password_form.pongo2":      true,
+	"pages/password_reset.pongo2":     true,
 	"pages/profile.pongo2":              true,
 	"pages/register.pongo2":             true,
 	"pages/settings/api_tokens.pongo2":  true,
@@ -506,6 +521,9 @@ func TestAllAdminTemplatesRender(t *testing.T) {
 					"TotalQueues":   5,
 					"ActiveTickets": 50,
 				}
+				ctx["PluginPages"] = []map[string]interface{}{
+					{"Title": "Jira Sync", "PluginName": "jira-sync", "URL": "/admin/plugins/jira-sync/pages/settings"},
+				}
 				return ctx
 			}(),
 		},
@@ -1118,6 +1136,16 @@ func TestAllAdminTemplatesRender(t *testing.T) {
 			template: "pages/admin/dynamic_field_import.pongo2",
 			ctx:      adminContext(),
 		},
+		{
+			name:     "admin/customer_import",
+			template: "pages/admin/customer_import.pongo2",
+			ctx: func() pongo2.Context {
+				ctx := adminContext()
+				ctx["ShowUpload"] = true
+				ctx["Kind"] = "users"
+				return ctx
+			}(),
+		},
 		{
 			name:     "admin/webservices",
 			template: "pages/admin/webservices.pongo2",
@@ -1173,6 +1201,78 @@ func TestAllAdminTemplatesRender(t *testing.T) {
 				return ctx
 			}(),
 		},
+		{
+			name:     "admin/sso_providers",
+			template: "pages/admin/sso_providers.pongo2",
+			ctx: func() pongo2.Context {
+				ctx := adminContext()
+				ctx["Providers"] = []map[string]interface{}{
+					{"ID": 1, "Name": "Company Login", "Protocol": "oidc", "Frontend": "agent", "JITProvisioning": true, "ValidID": 1},
+					{"ID": 2, "Name": "Partner IdP", "Protocol": "saml", "Frontend": "customer", "ValidID": 2},
+				}
+				return ctx
+			}(),
+		},
+		{
+			name:     "admin/sysconfig",
+			template: "pages/admin/sysconfig.pongo2",
+			ctx: func() pongo2.Context {
+				ctx := adminContext()
+				ctx["Navigations"] = []string{"Core"}
+				ctx["Settings"] = []map[string]interface{}{
+					{"Name": "Ticket::Hook", "Description": "Ticket number prefix", "Navigation": "Core", "Type": "string", "Value": "Ticket#", "Default": "Ticket#"},
+				}
+				ctx["Deployments"] = []map[string]interface{}{}
+				return ctx
+			}(),
+		},
+		{
+			name:     "admin/sysconfig_history",
+			template: "pages/admin/sysconfig_history.pongo2",
+			ctx: func() pongo2.Context {
+				ctx := adminContext()
+				ctx["Deployments"] = []map[string]interface{}{}
+				ctx["SelectedID"] = 0
+				return ctx
+			}(),
+		},
+		{
+			name:     "admin/maintenance",
+			template: "pages/admin/maintenance.pongo2",
+			ctx: func() pongo2.Context {
+				ctx := adminContext()
+				ctx["MaintenanceStatus"] = map[string]interface{}{"Maintenance": false}
+				ctx["Windows"] = []map[string]interface{}{}
+				ctx["Announcements"] = []map[string]interface{}{}
+				return ctx
+			}(),
+		},
+		{
+			name:     "admin/jobs",
+			template: "pages/admin/jobs.pongo2",
+			ctx: func() pongo2.Context {
+				ctx := adminContext()
+				ctx["Enabled"] = false
+				ctx["Queues"] = []map[string]interface{}{}
+				ctx["FailedJobs"] = []map[string]interface{}{}
+				return ctx
+			}(),
+		},
+		{
+			name:     "admin/sso_provider_form",
+			template: "pages/admin/sso_provider_form.pongo2",
+			ctx: func() pongo2.Context {
+				ctx := adminContext()
+				ctx["IsNew"] = false
+				ctx["Provider"] = map[string]interface{}{"ID": 2, "Name": "Partner IdP"}
+				ctx["ProviderJSON"] = `{"id":2,"name":"Partner IdP","protocol":"saml","config":{}}`
+				ctx["Groups"] = []map[string]interface{}{{"ID": 1, "Name": "users"}}
+				ctx["CallbackURL"] = "https://helpdesk.example.com/auth/sso/2/callback"
+				ctx["ACSURL"] = "https://helpdesk.example.com/auth/sso/2/acs"
+				ctx["MetadataURL"] = "https://helpdesk.example.com/auth/sso/2/metadata"
+				return ctx
+			}(),
+		},
 		{
 			name:     "admin/sessions",
 			template: "pages/admin/sessions.pongo2",
@@ -1252,6 +1352,49 @@ func TestAllAdminTemplatesRender(t *testing.T) {
 				return ctx
 			}(),
 		},
+		{
+			name:     "admin/plugin_page",
+			template: "pages/admin/plugin_page.pongo2",
+			ctx: func() pongo2.Context {
+				ctx := adminContext()
+				ctx["Page"] = map[string]interface{}{"ID": "settings", "Title": "Jira Sync", "PluginName": "jira-sync"}
+				ctx["Tabs"] = []map[string]interface{}{
+					{"ID": "settings", "Title": "Jira Sync", "URL": "/admin/plugins/jira-sync/pages/settings"},
+					{"ID": "mapping", "Title": "Field mapping", "Icon": "fa-arrows-left-right", "URL": "/admin/plugins/jira-sync/pages/mapping"},
+				}
+				ctx["Content"] = `<form method="post" action="/admin/plugins/jira-sync/pages/settings"><input name="url"></form>`
+				return ctx
+			}(),
+		},
+		{
+			name:     "admin/appearance",
+			template: "pages/admin/appearance.pongo2",
+			ctx: func() pongo2.Context {
+				ctx := adminContext()
+				ctx["Tenants"] = []map[string]interface{}{{"CustomerID": "ACME", "Name": "Acme Corp"}}
+				ctx["Defaults"] = map[string]interface{}{"ProductName": "GoatFlow"}
+				return ctx
+			}(),
+		},
+		{
+			name:     "admin/feature_flags",
+			template: "pages/admin/feature_flags.pongo2",
+			ctx: func() pongo2.Context {
+				ctx := adminContext()
+				ctx["Groups"] = []string{"admin", "users"}
+				return ctx
+			}(),
+		},
+		{
+			name:     "admin/system_insights",
+			template: "pages/admin/system_insights.pongo2",
+			ctx:      adminContext(),
+		},
+		{
+			name:     "admin/helpdesk_imports",
+			template: "pages/admin/helpdesk_imports.pongo2",
+			ctx:      adminContext(),
+		},
 	}
 
 	for _, tt := range tests {
@@ -1336,6 +1479,40 @@ func TestAllCustomerTemplatesRender(t *testing.T) {
 		template string
 		ctx      pongo2.Context
 	}{
+		{
+			name:     "customer/survey",
+			template: "pages/customer/survey.pongo2",
+			ctx: func() pongo2.Context {
+				ctx := customerContext()
+				ctx["State"] = "form"
+				ctx["Survey"] = map[string]interface{}{"Name": "Support survey"}
+				ctx["Questions"] = []map[string]interface{}{
+					{"ID": "q1", "Label": "How satisfied are you?", "Type": "nps", "Choices": []string{"0", "10"}},
+				}
+				return ctx
+			}(),
+		},
+		{
+			name:     "customer/survey_expired",
+			template: "pages/customer/survey.pongo2",
+			ctx: func() pongo2.Context {
+				ctx := customerContext()
+				ctx["State"] = "expired"
+				return ctx
+			}(),
+		},
+		{
+			name:     "customer/register",
+			template: "pages/customer/register.pongo2",
+			ctx: func() pongo2.Context {
+				ctx := customerContext()
+				ctx["State"] = "form"
+				ctx["Captcha"] = map[string]interface{}{"Provider": "turnstile", "SiteKey": "site-key"}
+				ctx["Input"] = map[string]interface{}{"Email": "jane@example.com", "FirstName": "Jane", "LastName": "Doe"}
+				ctx["error"] = "The passwords do not match"
+				return ctx
+			}(),
+		},
 		{
 			name:     "customer/company_info",
 			template: "pages/customer/company_info.pongo2",
@@ -1498,6 +1675,20 @@ func TestAllDashboardTemplatesRender(t *testing.T) {
 				return ctx
 			}(),
 		},
+		{
+			name:     "dashboard with plugin widgets",
+			template: "pages/dashboard.pongo2",
+			ctx: func() pongo2.Context {
+				ctx := baseContext()
+				ctx["Stats"] = map[string]interface{}{}
+				ctx["PluginWidgets"] = []map[string]interface{}{
+					{"ID": "hello-widget", "Title": "Hello", "PluginName": "hello", "HTML": "<p>Hi</p>",
+						"Refreshable": true, "RefreshSec": 60, "PollURL": "/api/dashboard/plugin-widgets/hello/hello-widget"},
+					{"ID": "static", "Title": "Static", "PluginName": "other", "FrameURL": "/api/v1/plugins/other/widgets/static/frame"},
+				}
+				return ctx
+			}(),
+		},
 		{
 			name:     "dashboard-simple",
 			template: "pages/dashboard-simple.pongo2",
@@ -1731,6 +1922,18 @@ func TestAllMiscTemplatesRender(t *testing.T) {
 				return ctx
 			}(),
 		},
+		{
+			name:     "password_reset",
+			template: "pages/password_reset.pongo2",
+			ctx: func() pongo2.Context {
+				ctx := baseContext()
+				ctx["State"] = "request"
+				ctx["BasePath"] = ""
+				ctx["Login"] = "jane@example.com"
+				ctx["error"] = "Too many requests. Please try again in 5 minutes."
+				return ctx
+			}(),
+		},
 		{
 			name:     "profile",
 			template: "pages/profile.pongo2",
@@ -0,0 +1,58 @@
+package testutil
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync/atomic"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/goatkit/goatflow/internal/database"
+	"github.com/goatkit/goatflow/migrations"
+)
+
+var migratedDBs atomic.Int64
+
+// MigratedDB returns a private in-memory SQLite database with every
+// migration and the required lookups applied, so tests run against the real
+// schema instead of tables they create themselves. The database is closed
+// when the test ends.
+func MigratedDB(t testing.TB) *sql.DB {
+	t.Helper()
+
+	// Each database gets its own name; a shared cache lets the connections
+	// of one database see the same data.
+	dsn := fmt.Sprintf("file:testutil_%d?mode=memory&cache=shared&_busy_timeout=5000", migratedDBs.Add(1))
+	db, err := sql.Open("sqlite3", dsn)
+	if err != nil {
+		t.Fatalf("open test database: %v", err)
+	}
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = db.Close() })
+
+	m, err := database.NewMigrator(db, "sqlite", migrations.FS)
+	if err != nil {
+		t.Fatalf("load migrations: %v", err)
+	}
+	if _, err := m.Up(context.Background()); err != nil {
+		t.Fatalf("migrate test database: %v", err)
+	}
+	return db
+}
+
+// UseMigratedDB is MigratedDB for code that calls database.GetDB. The
+// database is injected with database.SetDB until the test ends.
+func UseMigratedDB(t testing.TB) *sql.DB {
+	t.Helper()
+	db := MigratedDB(t)
+	t.Setenv("TEST_DB_DRIVER", "sqlite")
+	database.SetDB(db)
+	database.ResetAdapterForTest()
+	t.Cleanup(func() {
+		database.ResetDB()
+		database.ResetAdapterForTest()
+	})
+	return db
+}
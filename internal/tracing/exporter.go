@@ -0,0 +1,396 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultEndpoint is the OTLP/HTTP port of a collector on the same host.
+const DefaultEndpoint = "http://localhost:4318"
+
+// scopeName is reported as the instrumentation scope of every span.
+const scopeName = "github.com/goatkit/goatflow/internal/tracing"
+
+// ExporterConfig configures an OTLP/HTTP exporter.
+type ExporterConfig struct {
+	// Endpoint is the collector's base URL; /v1/traces is appended unless
+	// the URL already ends with it. Defaults to DefaultEndpoint.
+	Endpoint string
+	// Headers are sent with every export, e.g. an API key for a hosted
+	// backend.
+	Headers map[string]string
+	// ServiceName and ServiceVersion identify this process in the backend.
+	ServiceName    string
+	ServiceVersion string
+	// BatchSize is the most spans sent in one request (default 512).
+	BatchSize int
+	// QueueSize is the most spans waiting for export (default 2048). Spans
+	// ended while the queue is full are dropped.
+	QueueSize int
+	// Interval is how often queued spans are sent (default 5s).
+	Interval time.Duration
+	// Timeout bounds each export request (default 10s).
+	Timeout time.Duration
+	// Client overrides the HTTP client.
+	Client *http.Client
+}
+
+// Exporter sends ended spans to a collector in batches, as OTLP/HTTP with
+// JSON encoding.
+type Exporter struct {
+	url      string
+	headers  map[string]string
+	resource []otlpKeyValue
+	client   *http.Client
+	timeout  time.Duration
+
+	batchSize int
+	interval  time.Duration
+	queue     chan *Span
+	flush     chan chan struct{}
+	stop      chan struct{}
+	done      chan struct{}
+	stopOnce  sync.Once
+
+	dropped atomic.Int64
+	failing bool // only touched by run
+}
+
+// NewExporter starts an exporter. Call Shutdown to send the remaining spans.
+func NewExporter(cfg ExporterConfig) (*Exporter, error) {
+	endpoint := strings.TrimSpace(cfg.Endpoint)
+	if endpoint == "" {
+		endpoint = DefaultEndpoint
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("tracing: invalid OTLP endpoint %q", cfg.Endpoint)
+	}
+	if !strings.HasSuffix(u.Path, "/v1/traces") {
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/v1/traces"
+	}
+
+	serviceName := cfg.ServiceName
+	if serviceName == "" {
+		serviceName = "goatflow"
+	}
+	resource := []otlpKeyValue{keyValue(String("service.name", serviceName))}
+	if cfg.ServiceVersion != "" {
+		resource = append(resource, keyValue(String("service.version", cfg.ServiceVersion)))
+	}
+
+	e := &Exporter{
+		url:       u.String(),
+		headers:   cfg.Headers,
+		resource:  resource,
+		client:    cfg.Client,
+		timeout:   cfg.Timeout,
+		batchSize: cfg.BatchSize,
+		interval:  cfg.Interval,
+		flush:     make(chan chan struct{}),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	if e.client == nil {
+		e.client = &http.Client{}
+	}
+	if e.timeout <= 0 {
+		e.timeout = 10 * time.Second
+	}
+	if e.batchSize <= 0 {
+		e.batchSize = 512
+	}
+	if e.interval <= 0 {
+		e.interval = 5 * time.Second
+	}
+	queueSize := cfg.QueueSize
+	if queueSize <= 0 {
+		queueSize = 2048
+	}
+	e.queue = make(chan *Span, queueSize)
+
+	go e.run()
+	return e, nil
+}
+
+// URL returns the address spans are posted to.
+func (e *Exporter) URL() string {
+	return e.url
+}
+
+// Dropped returns the number of spans lost because the queue was full.
+func (e *Exporter) Dropped() int64 {
+	return e.dropped.Load()
+}
+
+func (e *Exporter) enqueue(span *Span) {
+	select {
+	case <-e.stop:
+		e.dropped.Add(1)
+		return
+	default:
+	}
+	select {
+	case e.queue <- span:
+	default:
+		e.dropped.Add(1)
+	}
+}
+
+// Flush sends the queued spans now.
+func (e *Exporter) Flush(ctx context.Context) error {
+	ack := make(chan struct{})
+	select {
+	case e.flush <- ack:
+	case <-e.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case <-ack:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Shutdown sends the queued spans and stops the exporter. Spans ended
+// afterwards are dropped.
+func (e *Exporter) Shutdown(ctx context.Context) error {
+	e.stopOnce.Do(func() { close(e.stop) })
+	select {
+	case <-e.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (e *Exporter) run() {
+	defer close(e.done)
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	batch := make([]*Span, 0, e.batchSize)
+	send := func() {
+		if len(batch) > 0 {
+			e.send(batch)
+			batch = batch[:0]
+		}
+	}
+	drain := func() {
+		for {
+			select {
+			case span := <-e.queue:
+				batch = append(batch, span)
+				if len(batch) >= e.batchSize {
+					send()
+				}
+			default:
+				send()
+				return
+			}
+		}
+	}
+
+	for {
+		select {
+		case span := <-e.queue:
+			batch = append(batch, span)
+			if len(batch) >= e.batchSize {
+				send()
+			}
+		case <-ticker.C:
+			send()
+		case ack := <-e.flush:
+			drain()
+			close(ack)
+		case <-e.stop:
+			drain()
+			return
+		}
+	}
+}
+
+func (e *Exporter) send(batch []*Span) {
+	err := e.post(batch)
+	switch {
+	case err != nil && !e.failing:
+		e.failing = true
+		log.Printf("tracing: export of %d span(s) failed (further failures are not logged until an export succeeds): %v", len(batch), err)
+	case err == nil && e.failing:
+		e.failing = false
+		log.Printf("tracing: exporting spans to %s again", e.url)
+	}
+}
+
+func (e *Exporter) post(batch []*Span) error {
+	body, err := json.Marshal(e.request(batch))
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), e.timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("collector returned %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// OTLP/JSON payload, see opentelemetry-proto's trace_service.proto. IDs are
+// hex and 64-bit integers are strings, as the JSON mapping requires.
+
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Events            []otlpEvent    `json:"events,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpEvent struct {
+	TimeUnixNano string         `json:"timeUnixNano"`
+	Name         string         `json:"name"`
+	Attributes   []otlpKeyValue `json:"attributes,omitempty"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpAnyValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+}
+
+func (e *Exporter) request(batch []*Span) otlpRequest {
+	spans := make([]otlpSpan, 0, len(batch))
+	for _, s := range batch {
+		spans = append(spans, s.otlp())
+	}
+	return otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: e.resource},
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: scopeName}, Spans: spans}},
+	}}}
+}
+
+func (s *Span) otlp() otlpSpan {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := otlpSpan{
+		TraceID:           s.sc.TraceID.String(),
+		SpanID:            s.sc.SpanID.String(),
+		Name:              s.name,
+		Kind:              int(s.kind),
+		StartTimeUnixNano: unixNano(s.start),
+		EndTimeUnixNano:   unixNano(s.end),
+		Attributes:        keyValues(s.attrs),
+		Status:            otlpStatus{Code: s.statusCode, Message: s.statusMessage},
+	}
+	if s.parent.IsValid() {
+		out.ParentSpanID = s.parent.String()
+	}
+	for _, ev := range s.events {
+		out.Events = append(out.Events, otlpEvent{TimeUnixNano: unixNano(ev.time), Name: ev.name, Attributes: keyValues(ev.attrs)})
+	}
+	return out
+}
+
+func unixNano(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+func keyValues(attrs []Attribute) []otlpKeyValue {
+	if len(attrs) == 0 {
+		return nil
+	}
+	out := make([]otlpKeyValue, 0, len(attrs))
+	for _, a := range attrs {
+		out = append(out, keyValue(a))
+	}
+	return out
+}
+
+func keyValue(a Attribute) otlpKeyValue {
+	kv := otlpKeyValue{Key: a.Key}
+	switch v := a.Value.(type) {
+	case string:
+		kv.Value.StringValue = &v
+	case int64:
+		s := strconv.FormatInt(v, 10)
+		kv.Value.IntValue = &s
+	case int:
+		s := strconv.Itoa(v)
+		kv.Value.IntValue = &s
+	case float64:
+		kv.Value.DoubleValue = &v
+	case bool:
+		kv.Value.BoolValue = &v
+	default:
+		s := fmt.Sprint(v)
+		kv.Value.StringValue = &s
+	}
+	return kv
+}
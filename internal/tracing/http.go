@@ -0,0 +1,62 @@
+package tracing
+
+import (
+	"net/http"
+	"net/url"
+)
+
+// Transport is an http.RoundTripper that records a client span for every
+// request and sends the traceparent header, so the receiving service can
+// join the trace.
+type Transport struct {
+	// Base makes the requests; nil means http.DefaultTransport.
+	Base http.RoundTripper
+}
+
+// NewTransport wraps base, or http.DefaultTransport when base is nil.
+func NewTransport(base http.RoundTripper) *Transport {
+	return &Transport{Base: base}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if !Enabled() {
+		return base.RoundTrip(req)
+	}
+
+	ctx, span := StartClient(req.Context(), req.Method+" "+req.URL.Host,
+		String("http.request.method", req.Method),
+		String("server.address", req.URL.Hostname()),
+		String("url.full", redactURL(req.URL)),
+	)
+	defer span.End()
+
+	// A RoundTripper must not modify the caller's request.
+	out := req.Clone(ctx)
+	Inject(ctx, out.Header)
+	resp, err := base.RoundTrip(out)
+	if err != nil {
+		span.RecordError(err)
+		return nil, err
+	}
+	span.SetAttributes(Int("http.response.status_code", resp.StatusCode))
+	if resp.StatusCode >= http.StatusBadRequest {
+		span.SetError(resp.Status)
+	}
+	return resp, nil
+}
+
+// redactURL drops credentials and the query string, which may carry
+// tokens or ticket data, from a URL recorded on a span.
+func redactURL(u *url.URL) string {
+	clean := *u
+	clean.User = nil
+	clean.RawQuery = ""
+	clean.ForceQuery = false
+	clean.Fragment = ""
+	return clean.String()
+}
@@ -0,0 +1,89 @@
+package tracing
+
+import (
+	"context"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// TraceParentHeader is the W3C Trace Context header.
+const TraceParentHeader = "traceparent"
+
+// FormatTraceParent returns sc as a traceparent value, or "" when sc is
+// not valid.
+func FormatTraceParent(sc SpanContext) string {
+	if !sc.IsValid() {
+		return ""
+	}
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return "00-" + sc.TraceID.String() + "-" + sc.SpanID.String() + "-" + flags
+}
+
+// ParseTraceParent parses a traceparent value. Malformed values, and the
+// all-zero IDs the specification forbids, give an invalid SpanContext.
+func ParseTraceParent(value string) SpanContext {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" {
+		return SpanContext{}
+	}
+	// Version 00 has exactly four fields; later versions may append more.
+	if parts[0] == "00" && len(parts) != 4 {
+		return SpanContext{}
+	}
+	var sc SpanContext
+	if len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return SpanContext{}
+	}
+	// IDs are lowercase hex; hex.Decode alone would accept uppercase.
+	if strings.ToLower(parts[1]) != parts[1] || strings.ToLower(parts[2]) != parts[2] {
+		return SpanContext{}
+	}
+	if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil {
+		return SpanContext{}
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil {
+		return SpanContext{}
+	}
+	var flags [1]byte
+	if _, err := hex.Decode(flags[:], []byte(parts[3])); err != nil {
+		return SpanContext{}
+	}
+	sc.Sampled = flags[0]&0x01 == 0x01
+	sc.Remote = true
+	if !sc.IsValid() {
+		return SpanContext{}
+	}
+	return sc
+}
+
+// TraceParent returns the traceparent value for calls made from ctx, or ""
+// when ctx is not part of a trace.
+func TraceParent(ctx context.Context) string {
+	return FormatTraceParent(SpanContextFromContext(ctx))
+}
+
+// ContextWithTraceParent returns a context continuing the trace named by a
+// traceparent value. An empty or malformed value returns ctx unchanged.
+func ContextWithTraceParent(ctx context.Context, traceParent string) context.Context {
+	if traceParent == "" {
+		return ctx
+	}
+	return ContextWithRemoteSpanContext(ctx, ParseTraceParent(traceParent))
+}
+
+// Inject sets the traceparent header for a request made from ctx.
+func Inject(ctx context.Context, header http.Header) {
+	if value := TraceParent(ctx); value != "" {
+		header.Set(TraceParentHeader, value)
+	}
+}
+
+// Extract returns a context continuing the trace of an incoming request's
+// traceparent header, if it has one.
+func Extract(ctx context.Context, header http.Header) context.Context {
+	return ContextWithTraceParent(ctx, header.Get(TraceParentHeader))
+}
@@ -0,0 +1,143 @@
+package tracing
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Status codes, numbered as in OTLP.
+const (
+	statusUnset = 0
+	statusError = 2
+)
+
+// Span is a timed unit of work. All methods are safe on a nil *Span, which
+// is what Start returns while tracing is off.
+type Span struct {
+	tracer *Tracer
+	name   string
+	kind   SpanKind
+	sc     SpanContext
+	parent SpanID
+	start  time.Time
+
+	mu            sync.Mutex
+	end           time.Time
+	attrs         []Attribute
+	events        []spanEvent
+	statusCode    int
+	statusMessage string
+	ended         bool
+}
+
+type spanEvent struct {
+	name  string
+	time  time.Time
+	attrs []Attribute
+}
+
+// SpanContext returns the IDs and sampling flag of the span.
+func (s *Span) SpanContext() SpanContext {
+	if s == nil {
+		return SpanContext{}
+	}
+	return s.sc
+}
+
+// IsRecording reports whether the span is sampled and not yet ended.
+func (s *Span) IsRecording() bool {
+	if s == nil || !s.sc.Sampled {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return !s.ended
+}
+
+// SetName replaces the span name, e.g. once the matched route is known.
+func (s *Span) SetName(name string) {
+	if !s.IsRecording() {
+		return
+	}
+	s.mu.Lock()
+	s.name = name
+	s.mu.Unlock()
+}
+
+// SetAttributes adds attributes to the span.
+func (s *Span) SetAttributes(attrs ...Attribute) {
+	if !s.IsRecording() {
+		return
+	}
+	s.mu.Lock()
+	s.attrs = append(s.attrs, attrs...)
+	s.mu.Unlock()
+}
+
+// SetError marks the span as failed with message.
+func (s *Span) SetError(message string) {
+	if !s.IsRecording() {
+		return
+	}
+	s.mu.Lock()
+	s.statusCode = statusError
+	s.statusMessage = message
+	s.mu.Unlock()
+}
+
+// RecordError marks the span as failed and adds an exception event for
+// err. A nil err is ignored.
+func (s *Span) RecordError(err error) {
+	if err == nil || !s.IsRecording() {
+		return
+	}
+	s.mu.Lock()
+	s.statusCode = statusError
+	s.statusMessage = err.Error()
+	s.events = append(s.events, spanEvent{
+		name: "exception",
+		time: time.Now(),
+		attrs: []Attribute{
+			String("exception.type", fmt.Sprintf("%T", err)),
+			String("exception.message", err.Error()),
+		},
+	})
+	s.mu.Unlock()
+}
+
+// End ends the span and queues it for export. Later calls do nothing.
+func (s *Span) End() {
+	if s == nil || !s.sc.Sampled {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.end = time.Now()
+	s.mu.Unlock()
+	s.tracer.export(s)
+}
+
+// drop ends the span without exporting it.
+func (s *Span) drop() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	s.ended = true
+	s.mu.Unlock()
+}
+
+// Finish records err, if any, and ends the span. It suits functions with a
+// named error result:
+//
+//	ctx, span := tracing.Start(ctx, "work")
+//	defer func() { span.Finish(err) }()
+func (s *Span) Finish(err error) {
+	s.RecordError(err)
+	s.End()
+}
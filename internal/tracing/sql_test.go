@@ -0,0 +1,56 @@
+package tracing
+
+import (
+	"context"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenDB_RecordsStatementsInTrace(t *testing.T) {
+	collector, exporter := newTestCollector(t)
+	useTracer(t, 1, exporter)
+
+	db, err := OpenDB("sqlite3", ":memory:")
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	// Outside a trace nothing is recorded.
+	_, err = db.Exec(`CREATE TABLE ticket (id INTEGER PRIMARY KEY, title TEXT)`)
+	require.NoError(t, err)
+
+	ctx, parent := Start(context.Background(), "create ticket")
+	_, err = db.ExecContext(ctx, `INSERT INTO ticket (title) VALUES (?)`, "printer on fire")
+	require.NoError(t, err)
+	var title string
+	require.NoError(t, db.QueryRowContext(ctx, `SELECT title FROM ticket WHERE id = ?`, 1).Scan(&title))
+	assert.Equal(t, "printer on fire", title)
+	_, err = db.QueryContext(ctx, `SELECT nope FROM ticket`)
+	assert.Error(t, err)
+	parent.End()
+
+	spans := collector.spans(t, exporter)
+	var names []string
+	for _, s := range spans {
+		if s.Name == "create ticket" {
+			continue
+		}
+		names = append(names, s.Name)
+		assert.Equal(t, parent.SpanContext().SpanID.String(), s.ParentSpanID)
+		assert.Equal(t, int(SpanKindClient), s.Kind)
+		assert.Equal(t, "sqlite3", *attr(s, "db.system").StringValue)
+	}
+	assert.Equal(t, []string{"INSERT", "SELECT", "SELECT"}, names)
+	last := spans[len(spans)-2]
+	assert.Equal(t, "SELECT nope FROM ticket", *attr(last, "db.query.text").StringValue)
+	assert.Equal(t, statusError, last.Status.Code)
+}
+
+func TestStatementOperation(t *testing.T) {
+	assert.Equal(t, "SELECT", statementOperation("  select * from ticket"))
+	assert.Equal(t, "WITH", statementOperation("(with x as (select 1) select * from x)"))
+	assert.Equal(t, "SQL", statementOperation(""))
+}
@@ -0,0 +1,274 @@
+// Package tracing records OpenTelemetry-compatible spans and exports them to
+// an OTLP/HTTP collector.
+//
+// Spans follow the W3C Trace Context model: a trace ID shared by every span
+// of an operation, a span ID per unit of work and a sampled flag carried in
+// the traceparent header. Start creates a span as a child of the span in the
+// context; without one it starts a new trace, sampled by the configured
+// ratio. When tracing is not set up Start returns a nil *Span, whose methods
+// do nothing, so instrumented code needs no checks.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// TraceID identifies a trace.
+type TraceID [16]byte
+
+// IsValid reports whether the ID is not all zeros.
+func (t TraceID) IsValid() bool { return t != TraceID{} }
+
+// String returns the ID as 32 lowercase hex digits.
+func (t TraceID) String() string { return hex.EncodeToString(t[:]) }
+
+// SpanID identifies a span within a trace.
+type SpanID [8]byte
+
+// IsValid reports whether the ID is not all zeros.
+func (s SpanID) IsValid() bool { return s != SpanID{} }
+
+// String returns the ID as 16 lowercase hex digits.
+func (s SpanID) String() string { return hex.EncodeToString(s[:]) }
+
+// SpanContext is the part of a span that crosses process boundaries.
+type SpanContext struct {
+	TraceID TraceID
+	SpanID  SpanID
+	Sampled bool
+	// Remote is set for span contexts received from another process.
+	Remote bool
+}
+
+// IsValid reports whether both IDs are set.
+func (sc SpanContext) IsValid() bool { return sc.TraceID.IsValid() && sc.SpanID.IsValid() }
+
+// SpanKind is the role of a span in a trace.
+type SpanKind int
+
+// Span kinds, numbered as in OTLP.
+const (
+	SpanKindInternal SpanKind = 1
+	SpanKindServer   SpanKind = 2
+	SpanKindClient   SpanKind = 3
+)
+
+// Attribute is a key/value pair describing a span. Values are strings,
+// integers, floats or booleans.
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+// String returns a string attribute.
+func String(key, value string) Attribute { return Attribute{Key: key, Value: value} }
+
+// Int returns an integer attribute.
+func Int(key string, value int) Attribute { return Attribute{Key: key, Value: int64(value)} }
+
+// Int64 returns an integer attribute.
+func Int64(key string, value int64) Attribute { return Attribute{Key: key, Value: value} }
+
+// Bool returns a boolean attribute.
+func Bool(key string, value bool) Attribute { return Attribute{Key: key, Value: value} }
+
+type spanKey struct{}
+type remoteKey struct{}
+
+// ContextWithSpan returns a context carrying span.
+func ContextWithSpan(ctx context.Context, span *Span) context.Context {
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+// SpanFromContext returns the span in ctx, or nil.
+func SpanFromContext(ctx context.Context) *Span {
+	if ctx == nil {
+		return nil
+	}
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// ContextWithRemoteSpanContext returns a context whose next span continues
+// the trace of another process.
+func ContextWithRemoteSpanContext(ctx context.Context, sc SpanContext) context.Context {
+	if !sc.IsValid() {
+		return ctx
+	}
+	sc.Remote = true
+	return context.WithValue(ctx, remoteKey{}, sc)
+}
+
+// SpanContextFromContext returns the span context new spans in ctx would be
+// children of: the current span's or, without one, the remote parent's.
+func SpanContextFromContext(ctx context.Context) SpanContext {
+	if ctx == nil {
+		return SpanContext{}
+	}
+	if span := SpanFromContext(ctx); span != nil {
+		return span.sc
+	}
+	sc, _ := ctx.Value(remoteKey{}).(SpanContext)
+	return sc
+}
+
+var global atomic.Pointer[Tracer]
+
+// SetTracer makes t the tracer used by Start. A nil t turns tracing off.
+func SetTracer(t *Tracer) {
+	global.Store(t)
+}
+
+// Enabled reports whether a tracer is set.
+func Enabled() bool {
+	return global.Load() != nil
+}
+
+// Start starts an internal span as a child of the span in ctx.
+func Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, *Span) {
+	return global.Load().start(ctx, SpanKindInternal, name, attrs)
+}
+
+// StartServer starts a span for handling a request from a client.
+func StartServer(ctx context.Context, name string, attrs ...Attribute) (context.Context, *Span) {
+	return global.Load().start(ctx, SpanKindServer, name, attrs)
+}
+
+// StartClient starts a span for a request to another service.
+func StartClient(ctx context.Context, name string, attrs ...Attribute) (context.Context, *Span) {
+	return global.Load().start(ctx, SpanKindClient, name, attrs)
+}
+
+// StartChild starts an internal span only when ctx already holds a
+// recording span, for work that is only worth tracing as part of a larger
+// operation, such as single database statements.
+func StartChild(ctx context.Context, kind SpanKind, name string, attrs ...Attribute) (context.Context, *Span) {
+	if !SpanFromContext(ctx).IsRecording() {
+		return ctx, nil
+	}
+	return global.Load().start(ctx, kind, name, attrs)
+}
+
+// Tracer creates spans and hands the sampled ones to its exporter.
+type Tracer struct {
+	ratio    float64
+	exporter *Exporter
+}
+
+// NewTracer creates a tracer that samples new traces with the given ratio
+// (0 to 1) and exports spans through exporter. Traces continued from a
+// parent follow the parent's sampling decision.
+func NewTracer(ratio float64, exporter *Exporter) *Tracer {
+	return &Tracer{ratio: ratio, exporter: exporter}
+}
+
+// Shutdown exports the spans still queued and stops the exporter.
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	if t == nil || t.exporter == nil {
+		return nil
+	}
+	return t.exporter.Shutdown(ctx)
+}
+
+func (t *Tracer) start(ctx context.Context, kind SpanKind, name string, attrs []Attribute) (context.Context, *Span) {
+	if t == nil {
+		return ctx, nil
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	parent := SpanContextFromContext(ctx)
+	span := &Span{tracer: t, name: name, kind: kind, start: time.Now()}
+	if parent.IsValid() {
+		span.sc = SpanContext{TraceID: parent.TraceID, Sampled: parent.Sampled}
+		span.parent = parent.SpanID
+	} else {
+		span.sc = SpanContext{TraceID: newTraceID(), Sampled: t.sample()}
+	}
+	span.sc.SpanID = newSpanID()
+	if span.sc.Sampled {
+		span.attrs = append(span.attrs, attrs...)
+	}
+	return ContextWithSpan(ctx, span), span
+}
+
+// sample decides whether a new trace is recorded.
+func (t *Tracer) sample() bool {
+	switch {
+	case t.ratio >= 1:
+		return true
+	case t.ratio <= 0:
+		return false
+	}
+	var b [8]byte
+	_, _ = rand.Read(b[:])
+	n := uint64(0)
+	for _, v := range b {
+		n = n<<8 | uint64(v)
+	}
+	return float64(n>>11)/(1<<53) < t.ratio
+}
+
+func (t *Tracer) export(span *Span) {
+	if t.exporter != nil {
+		t.exporter.enqueue(span)
+	}
+}
+
+func newTraceID() TraceID {
+	var id TraceID
+	for !id.IsValid() {
+		if _, err := rand.Read(id[:]); err != nil {
+			panic(fmt.Sprintf("tracing: read random trace ID: %v", err))
+		}
+	}
+	return id
+}
+
+func newSpanID() SpanID {
+	var id SpanID
+	for !id.IsValid() {
+		if _, err := rand.Read(id[:]); err != nil {
+			panic(fmt.Sprintf("tracing: read random span ID: %v", err))
+		}
+	}
+	return id
+}
+
+// Config configures tracing. It mirrors metrics.opentelemetry in the
+// application configuration.
+type Config struct {
+	Enabled        bool
+	Endpoint       string
+	Headers        map[string]string
+	ServiceName    string
+	ServiceVersion string
+	// Ratio is the share of new traces that are recorded, from 0 to 1.
+	Ratio float64
+}
+
+// Setup installs a tracer exporting to cfg.Endpoint and returns it, so the
+// caller can shut it down. When cfg.Enabled is false it installs nothing
+// and returns nil.
+func Setup(cfg Config) (*Tracer, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	exporter, err := NewExporter(ExporterConfig{
+		Endpoint:       cfg.Endpoint,
+		Headers:        cfg.Headers,
+		ServiceName:    cfg.ServiceName,
+		ServiceVersion: cfg.ServiceVersion,
+	})
+	if err != nil {
+		return nil, err
+	}
+	t := NewTracer(cfg.Ratio, exporter)
+	SetTracer(t)
+	return t, nil
+}
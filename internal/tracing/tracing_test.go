@@ -0,0 +1,272 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// useTracer installs a tracer for the duration of the test.
+func useTracer(t *testing.T, ratio float64, exporter *Exporter) *Tracer {
+	t.Helper()
+	tracer := NewTracer(ratio, exporter)
+	SetTracer(tracer)
+	t.Cleanup(func() { SetTracer(nil) })
+	return tracer
+}
+
+// testCollector is an OTLP/HTTP endpoint that keeps what it receives.
+type testCollector struct {
+	mu       sync.Mutex
+	requests []otlpRequest
+	headers  []http.Header
+}
+
+func newTestCollector(t *testing.T) (*testCollector, *Exporter) {
+	t.Helper()
+	c := &testCollector{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" {
+			http.NotFound(w, r)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		var req otlpRequest
+		if err := json.Unmarshal(body, &req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		c.mu.Lock()
+		c.requests = append(c.requests, req)
+		c.headers = append(c.headers, r.Header.Clone())
+		c.mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(srv.Close)
+
+	exporter, err := NewExporter(ExporterConfig{
+		Endpoint:       srv.URL,
+		Headers:        map[string]string{"X-Api-Key": "secret"},
+		ServiceName:    "goatflow-test",
+		ServiceVersion: "1.2.3",
+		Interval:       time.Hour,
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = exporter.Shutdown(context.Background()) })
+	return c, exporter
+}
+
+// spans flushes the exporter and returns every span received so far.
+func (c *testCollector) spans(t *testing.T, exporter *Exporter) []otlpSpan {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, exporter.Flush(ctx))
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var out []otlpSpan
+	for _, req := range c.requests {
+		for _, rs := range req.ResourceSpans {
+			for _, ss := range rs.ScopeSpans {
+				out = append(out, ss.Spans...)
+			}
+		}
+	}
+	return out
+}
+
+func attr(span otlpSpan, key string) *otlpAnyValue {
+	for _, kv := range span.Attributes {
+		if kv.Key == key {
+			v := kv.Value
+			return &v
+		}
+	}
+	return nil
+}
+
+func TestParseTraceParent(t *testing.T) {
+	valid := "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	sc := ParseTraceParent(valid)
+	require.True(t, sc.IsValid())
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", sc.TraceID.String())
+	assert.Equal(t, "00f067aa0ba902b7", sc.SpanID.String())
+	assert.True(t, sc.Sampled)
+	assert.Equal(t, valid, FormatTraceParent(sc))
+
+	assert.False(t, ParseTraceParent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00").Sampled)
+
+	for _, bad := range []string{
+		"",
+		"garbage",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e473-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902bz-01",
+	} {
+		assert.False(t, ParseTraceParent(bad).IsValid(), bad)
+	}
+}
+
+func TestStart_Disabled(t *testing.T) {
+	SetTracer(nil)
+	ctx, span := Start(context.Background(), "work")
+	assert.Nil(t, span)
+	assert.False(t, span.IsRecording())
+	span.SetAttributes(String("k", "v"))
+	span.RecordError(errors.New("boom"))
+	span.End()
+	assert.Equal(t, "", TraceParent(ctx))
+	assert.False(t, Enabled())
+}
+
+func TestStart_SamplingAndParents(t *testing.T) {
+	useTracer(t, 0, nil)
+
+	_, root := Start(context.Background(), "root")
+	require.NotNil(t, root)
+	assert.False(t, root.IsRecording(), "ratio 0 must not sample new traces")
+
+	remote := ParseTraceParent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	ctx := ContextWithRemoteSpanContext(context.Background(), remote)
+	ctx, server := StartServer(ctx, "server")
+	assert.True(t, server.IsRecording(), "a sampled parent decides for the child")
+	assert.Equal(t, remote.TraceID, server.SpanContext().TraceID)
+	assert.Equal(t, remote.SpanID, server.parent)
+
+	_, child := Start(ctx, "child")
+	assert.Equal(t, remote.TraceID, child.SpanContext().TraceID)
+	assert.Equal(t, server.SpanContext().SpanID, child.parent)
+	assert.NotEqual(t, server.SpanContext().SpanID, child.SpanContext().SpanID)
+}
+
+func TestStartChild_OnlyUnderRecordingSpan(t *testing.T) {
+	useTracer(t, 1, nil)
+
+	ctx, span := StartChild(context.Background(), SpanKindClient, "SELECT")
+	assert.Nil(t, span)
+	assert.Nil(t, SpanFromContext(ctx))
+
+	parentCtx, parent := Start(context.Background(), "request")
+	_, span = StartChild(parentCtx, SpanKindClient, "SELECT")
+	require.NotNil(t, span)
+	assert.Equal(t, parent.SpanContext().SpanID, span.parent)
+}
+
+func TestExporter_PostsOTLPJSON(t *testing.T) {
+	collector, exporter := newTestCollector(t)
+	useTracer(t, 1, exporter)
+
+	ctx, parent := StartServer(context.Background(), "GET /tickets/:id", String("http.route", "/tickets/:id"))
+	_, child := Start(ctx, "load ticket", Int("ticket.id", 42), Bool("cached", false))
+	child.RecordError(errors.New("not found"))
+	child.End()
+	child.End() // a second End is ignored
+	parent.End()
+
+	spans := collector.spans(t, exporter)
+	require.Len(t, spans, 2)
+	byName := map[string]otlpSpan{}
+	for _, s := range spans {
+		byName[s.Name] = s
+	}
+
+	server := byName["GET /tickets/:id"]
+	assert.Equal(t, int(SpanKindServer), server.Kind)
+	assert.Empty(t, server.ParentSpanID)
+	assert.Equal(t, parent.SpanContext().TraceID.String(), server.TraceID)
+	assert.Equal(t, "/tickets/:id", *attr(server, "http.route").StringValue)
+
+	work := byName["load ticket"]
+	assert.Equal(t, server.SpanID, work.ParentSpanID)
+	assert.Equal(t, server.TraceID, work.TraceID)
+	assert.Equal(t, "42", *attr(work, "ticket.id").IntValue)
+	assert.False(t, *attr(work, "cached").BoolValue)
+	assert.Equal(t, statusError, work.Status.Code)
+	assert.Equal(t, "not found", work.Status.Message)
+	require.Len(t, work.Events, 1)
+	assert.Equal(t, "exception", work.Events[0].Name)
+	assert.NotEqual(t, "", work.StartTimeUnixNano)
+
+	collector.mu.Lock()
+	defer collector.mu.Unlock()
+	assert.Equal(t, "secret", collector.headers[0].Get("X-Api-Key"))
+	resource := collector.requests[0].ResourceSpans[0].Resource.Attributes
+	assert.Contains(t, resource, keyValue(String("service.name", "goatflow-test")))
+	assert.Contains(t, resource, keyValue(String("service.version", "1.2.3")))
+}
+
+func TestExporter_DropsWhenQueueFull(t *testing.T) {
+	exporter, err := NewExporter(ExporterConfig{Endpoint: "http://127.0.0.1:1", QueueSize: 1, Interval: time.Hour})
+	require.NoError(t, err)
+	require.NoError(t, exporter.Shutdown(context.Background()))
+	useTracer(t, 1, exporter)
+
+	_, span := Start(context.Background(), "late")
+	span.End()
+	assert.Equal(t, int64(1), exporter.Dropped())
+}
+
+func TestNewExporter_Endpoint(t *testing.T) {
+	e, err := NewExporter(ExporterConfig{})
+	require.NoError(t, err)
+	assert.Equal(t, DefaultEndpoint+"/v1/traces", e.URL())
+	_ = e.Shutdown(context.Background())
+
+	e, err = NewExporter(ExporterConfig{Endpoint: "https://otlp.example.com/v1/traces"})
+	require.NoError(t, err)
+	assert.Equal(t, "https://otlp.example.com/v1/traces", e.URL())
+	_ = e.Shutdown(context.Background())
+
+	_, err = NewExporter(ExporterConfig{Endpoint: "otel-collector:4318"})
+	assert.Error(t, err)
+}
+
+func TestTransport_InjectsTraceParent(t *testing.T) {
+	collector, exporter := newTestCollector(t)
+	useTracer(t, 1, exporter)
+
+	var received string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Get(TraceParentHeader)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	ctx, parent := Start(context.Background(), "invoke")
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/api?token=abc", nil)
+	require.NoError(t, err)
+	client := &http.Client{Transport: NewTransport(nil)}
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	resp.Body.Close()
+	parent.End()
+
+	sc := ParseTraceParent(received)
+	require.True(t, sc.IsValid())
+	assert.Equal(t, parent.SpanContext().TraceID, sc.TraceID)
+	assert.Empty(t, req.Header.Get(TraceParentHeader), "the caller's request must not be modified")
+
+	var clientSpan otlpSpan
+	for _, s := range collector.spans(t, exporter) {
+		if s.Kind == int(SpanKindClient) {
+			clientSpan = s
+		}
+	}
+	assert.Equal(t, sc.SpanID.String(), clientSpan.SpanID, "the server sees the client span as its parent")
+	assert.Equal(t, "502", *attr(clientSpan, "http.response.status_code").IntValue)
+	assert.Equal(t, statusError, clientSpan.Status.Code)
+	assert.Equal(t, srv.URL+"/api", *attr(clientSpan, "url.full").StringValue)
+}
@@ -0,0 +1,11 @@
+// Package migrations embeds the versioned schema migrations, so binaries can
+// migrate a database without the migration files on disk.
+package migrations
+
+import "embed"
+
+// FS holds the mysql/ and postgres/ migrations. SQLite runs the postgres/
+// ones translated to its dialect.
+//
+//go:embed mysql/*.sql postgres/*.sql
+var FS embed.FS
@@ -0,0 +1,2 @@
+-- Remove API token IP allow-list
+ALTER TABLE user_api_tokens DROP COLUMN allowed_ips;
@@ -0,0 +1,2 @@
+-- Optional CIDR allow-list per API token (NULL = usable from any address)
+ALTER TABLE user_api_tokens ADD COLUMN allowed_ips JSON NULL AFTER scopes;
@@ -0,0 +1,60 @@
+-- Knowledge base: categories, articles and article versions
+
+CREATE TABLE IF NOT EXISTS kb_category (
+    id INT NOT NULL AUTO_INCREMENT,
+    name VARCHAR(200) NOT NULL,
+    parent_id INT NULL,
+    comments VARCHAR(250) NULL,
+    valid_id SMALLINT NOT NULL DEFAULT 1,
+    create_time DATETIME NOT NULL,
+    create_by INT NOT NULL,
+    change_time DATETIME NOT NULL,
+    change_by INT NOT NULL,
+    PRIMARY KEY (id),
+    UNIQUE KEY kb_category_name_parent (name, parent_id),
+    KEY FK_kb_category_parent_id (parent_id),
+    CONSTRAINT FK_kb_category_parent_id FOREIGN KEY (parent_id) REFERENCES kb_category (id),
+    CONSTRAINT FK_kb_category_valid_id FOREIGN KEY (valid_id) REFERENCES valid (id),
+    CONSTRAINT FK_kb_category_create_by FOREIGN KEY (create_by) REFERENCES users (id),
+    CONSTRAINT FK_kb_category_change_by FOREIGN KEY (change_by) REFERENCES users (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS kb_article (
+    id INT NOT NULL AUTO_INCREMENT,
+    category_id INT NOT NULL,
+    title VARCHAR(255) NOT NULL,
+    body MEDIUMTEXT NOT NULL,
+    keywords VARCHAR(500) NULL,
+    visibility VARCHAR(20) NOT NULL DEFAULT 'internal', -- 'internal', 'customer', 'public'
+    version INT NOT NULL DEFAULT 1,                      -- Current version number
+    valid_id SMALLINT NOT NULL DEFAULT 1,
+    create_time DATETIME NOT NULL,
+    create_by INT NOT NULL,
+    change_time DATETIME NOT NULL,
+    change_by INT NOT NULL,
+    PRIMARY KEY (id),
+    KEY FK_kb_article_category_id (category_id),
+    KEY kb_article_visibility (visibility, valid_id),
+    CONSTRAINT FK_kb_article_category_id FOREIGN KEY (category_id) REFERENCES kb_category (id),
+    CONSTRAINT FK_kb_article_valid_id FOREIGN KEY (valid_id) REFERENCES valid (id),
+    CONSTRAINT FK_kb_article_create_by FOREIGN KEY (create_by) REFERENCES users (id),
+    CONSTRAINT FK_kb_article_change_by FOREIGN KEY (change_by) REFERENCES users (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+-- Every saved revision of an article, including the current one
+CREATE TABLE IF NOT EXISTS kb_article_version (
+    id INT NOT NULL AUTO_INCREMENT,
+    article_id INT NOT NULL,
+    version INT NOT NULL,
+    title VARCHAR(255) NOT NULL,
+    body MEDIUMTEXT NOT NULL,
+    keywords VARCHAR(500) NULL,
+    visibility VARCHAR(20) NOT NULL,
+    change_note VARCHAR(250) NULL,
+    create_time DATETIME NOT NULL,
+    create_by INT NOT NULL,
+    PRIMARY KEY (id),
+    UNIQUE KEY kb_article_version_article_version (article_id, version),
+    CONSTRAINT FK_kb_article_version_article_id FOREIGN KEY (article_id) REFERENCES kb_article (id) ON DELETE CASCADE,
+    CONSTRAINT FK_kb_article_version_create_by FOREIGN KEY (create_by) REFERENCES users (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -0,0 +1,8 @@
+-- Applied plugin schema migrations (one row per plugin and version)
+CREATE TABLE IF NOT EXISTS plugin_schema_migration (
+    plugin_name VARCHAR(200) NOT NULL,
+    version INT NOT NULL,
+    description VARCHAR(250) NULL,
+    applied_at DATETIME NOT NULL,
+    PRIMARY KEY (plugin_name, version)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -0,0 +1,2 @@
+-- Remove report definitions
+DROP TABLE IF EXISTS report_definition;
@@ -0,0 +1,25 @@
+-- Report builder: saved report definitions with optional email schedule
+
+CREATE TABLE IF NOT EXISTS report_definition (
+    id INT NOT NULL AUTO_INCREMENT,
+    name VARCHAR(200) NOT NULL,
+    description VARCHAR(500) NULL,
+    metric VARCHAR(50) NOT NULL,
+    group_by VARCHAR(50) NOT NULL DEFAULT 'none',
+    time_bucket VARCHAR(20) NOT NULL DEFAULT 'none',
+    filters TEXT NULL,
+    schedule VARCHAR(100) NULL,
+    schedule_format VARCHAR(20) NULL,
+    recipient_group_ids VARCHAR(500) NULL,
+    last_sent_time DATETIME NULL,
+    valid_id SMALLINT NOT NULL DEFAULT 1,
+    create_time DATETIME NOT NULL,
+    create_by INT NOT NULL,
+    change_time DATETIME NOT NULL,
+    change_by INT NOT NULL,
+    PRIMARY KEY (id),
+    UNIQUE KEY report_definition_name (name),
+    CONSTRAINT FK_report_definition_valid_id FOREIGN KEY (valid_id) REFERENCES valid (id),
+    CONSTRAINT FK_report_definition_create_by FOREIGN KEY (create_by) REFERENCES users (id),
+    CONSTRAINT FK_report_definition_change_by FOREIGN KEY (change_by) REFERENCES users (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -0,0 +1,3 @@
+-- Remove ticket approvals
+DROP TABLE IF EXISTS ticket_approval_request;
+DROP TABLE IF EXISTS ticket_approval_rule;
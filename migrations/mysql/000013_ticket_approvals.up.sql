@@ -0,0 +1,46 @@
+-- Delegated approvals: rules that require a group's approval before a ticket
+-- action runs, and the approval requests raised under them
+
+CREATE TABLE IF NOT EXISTS ticket_approval_rule (
+    id INT NOT NULL AUTO_INCREMENT,
+    name VARCHAR(200) NOT NULL,
+    queue_id INT NULL,
+    action VARCHAR(20) NOT NULL,
+    approver_group_id INT NOT NULL,
+    comments VARCHAR(250) NULL,
+    valid_id SMALLINT NOT NULL DEFAULT 1,
+    create_time DATETIME NOT NULL,
+    create_by INT NOT NULL,
+    change_time DATETIME NOT NULL,
+    change_by INT NOT NULL,
+    PRIMARY KEY (id),
+    UNIQUE KEY ticket_approval_rule_name (name),
+    INDEX ticket_approval_rule_queue_action (queue_id, action),
+    CONSTRAINT FK_ticket_approval_rule_queue_id FOREIGN KEY (queue_id) REFERENCES queue (id),
+    CONSTRAINT FK_ticket_approval_rule_group_id FOREIGN KEY (approver_group_id) REFERENCES `groups` (id),
+    CONSTRAINT FK_ticket_approval_rule_valid_id FOREIGN KEY (valid_id) REFERENCES valid (id),
+    CONSTRAINT FK_ticket_approval_rule_create_by FOREIGN KEY (create_by) REFERENCES users (id),
+    CONSTRAINT FK_ticket_approval_rule_change_by FOREIGN KEY (change_by) REFERENCES users (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS ticket_approval_request (
+    id BIGINT NOT NULL AUTO_INCREMENT,
+    rule_id INT NOT NULL,
+    ticket_id BIGINT NOT NULL,
+    action VARCHAR(20) NOT NULL,
+    target_id INT NOT NULL,
+    reason TEXT NULL,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    requested_by INT NOT NULL,
+    requested_time DATETIME NOT NULL,
+    decided_by INT NULL,
+    decided_time DATETIME NULL,
+    decision_comment TEXT NULL,
+    PRIMARY KEY (id),
+    INDEX ticket_approval_request_ticket (ticket_id, status),
+    INDEX ticket_approval_request_status (status),
+    CONSTRAINT FK_ticket_approval_request_rule_id FOREIGN KEY (rule_id) REFERENCES ticket_approval_rule (id),
+    CONSTRAINT FK_ticket_approval_request_ticket_id FOREIGN KEY (ticket_id) REFERENCES ticket (id) ON DELETE CASCADE,
+    CONSTRAINT FK_ticket_approval_request_requested_by FOREIGN KEY (requested_by) REFERENCES users (id),
+    CONSTRAINT FK_ticket_approval_request_decided_by FOREIGN KEY (decided_by) REFERENCES users (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -0,0 +1,2 @@
+-- Remove single sign-on providers
+DROP TABLE IF EXISTS sso_provider;
@@ -0,0 +1,23 @@
+-- Single sign-on: OpenID Connect and SAML 2.0 identity providers for the
+-- agent and customer frontends
+
+CREATE TABLE IF NOT EXISTS sso_provider (
+    id INT NOT NULL AUTO_INCREMENT,
+    name VARCHAR(200) NOT NULL,
+    protocol VARCHAR(20) NOT NULL,
+    frontend VARCHAR(20) NOT NULL,
+    config TEXT NOT NULL,
+    group_mappings TEXT NULL,
+    jit_provisioning SMALLINT NOT NULL DEFAULT 0,
+    valid_id SMALLINT NOT NULL DEFAULT 1,
+    create_time DATETIME NOT NULL,
+    create_by INT NOT NULL,
+    change_time DATETIME NOT NULL,
+    change_by INT NOT NULL,
+    PRIMARY KEY (id),
+    UNIQUE KEY sso_provider_name (name),
+    INDEX sso_provider_frontend (frontend, valid_id),
+    CONSTRAINT FK_sso_provider_valid_id FOREIGN KEY (valid_id) REFERENCES valid (id),
+    CONSTRAINT FK_sso_provider_create_by FOREIGN KEY (create_by) REFERENCES users (id),
+    CONSTRAINT FK_sso_provider_change_by FOREIGN KEY (change_by) REFERENCES users (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -0,0 +1,36 @@
+-- Ticket type workflows: per-type creation defaults, dynamic field sets and
+-- allowed state transitions
+
+CREATE TABLE IF NOT EXISTS ticket_type_workflow (
+    type_id SMALLINT NOT NULL,
+    default_queue_id INT NULL,
+    default_priority_id SMALLINT NULL,
+    default_sla_id INT NULL,
+    change_time DATETIME NOT NULL,
+    change_by INT NOT NULL,
+    PRIMARY KEY (type_id),
+    CONSTRAINT FK_ticket_type_workflow_type_id FOREIGN KEY (type_id) REFERENCES ticket_type (id) ON DELETE CASCADE,
+    CONSTRAINT FK_ticket_type_workflow_queue_id FOREIGN KEY (default_queue_id) REFERENCES queue (id),
+    CONSTRAINT FK_ticket_type_workflow_priority_id FOREIGN KEY (default_priority_id) REFERENCES ticket_priority (id),
+    CONSTRAINT FK_ticket_type_workflow_sla_id FOREIGN KEY (default_sla_id) REFERENCES sla (id),
+    CONSTRAINT FK_ticket_type_workflow_change_by FOREIGN KEY (change_by) REFERENCES users (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS ticket_type_dynamic_field (
+    type_id SMALLINT NOT NULL,
+    field_id INT NOT NULL,
+    required SMALLINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (type_id, field_id),
+    CONSTRAINT FK_ticket_type_dynamic_field_type_id FOREIGN KEY (type_id) REFERENCES ticket_type (id) ON DELETE CASCADE,
+    CONSTRAINT FK_ticket_type_dynamic_field_field_id FOREIGN KEY (field_id) REFERENCES dynamic_field (id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS ticket_type_transition (
+    type_id SMALLINT NOT NULL,
+    from_state_id SMALLINT NOT NULL,
+    to_state_id SMALLINT NOT NULL,
+    PRIMARY KEY (type_id, from_state_id, to_state_id),
+    CONSTRAINT FK_ticket_type_transition_type_id FOREIGN KEY (type_id) REFERENCES ticket_type (id) ON DELETE CASCADE,
+    CONSTRAINT FK_ticket_type_transition_from_state FOREIGN KEY (from_state_id) REFERENCES ticket_state (id),
+    CONSTRAINT FK_ticket_type_transition_to_state FOREIGN KEY (to_state_id) REFERENCES ticket_state (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
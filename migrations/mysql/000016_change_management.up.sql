@@ -0,0 +1,38 @@
+-- Change management: the planned window and scope of change tickets, used
+-- for the change calendar and collision warnings
+
+CREATE TABLE IF NOT EXISTS ticket_change_plan (
+    ticket_id BIGINT NOT NULL,
+    planned_start DATETIME NOT NULL,
+    planned_end DATETIME NOT NULL,
+    risk VARCHAR(20) NOT NULL DEFAULT 'medium',
+    implementation_plan TEXT NULL,
+    backout_plan TEXT NULL,
+    create_time DATETIME NOT NULL,
+    create_by INT NOT NULL,
+    change_time DATETIME NOT NULL,
+    change_by INT NOT NULL,
+    PRIMARY KEY (ticket_id),
+    INDEX ticket_change_plan_window (planned_start, planned_end),
+    CONSTRAINT FK_ticket_change_plan_ticket_id FOREIGN KEY (ticket_id) REFERENCES ticket (id) ON DELETE CASCADE,
+    CONSTRAINT FK_ticket_change_plan_create_by FOREIGN KEY (create_by) REFERENCES users (id),
+    CONSTRAINT FK_ticket_change_plan_change_by FOREIGN KEY (change_by) REFERENCES users (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS ticket_change_service (
+    ticket_id BIGINT NOT NULL,
+    service_id INT NOT NULL,
+    PRIMARY KEY (ticket_id, service_id),
+    INDEX ticket_change_service_service (service_id),
+    CONSTRAINT FK_ticket_change_service_ticket_id FOREIGN KEY (ticket_id) REFERENCES ticket_change_plan (ticket_id) ON DELETE CASCADE,
+    CONSTRAINT FK_ticket_change_service_service_id FOREIGN KEY (service_id) REFERENCES service (id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS ticket_change_ci (
+    ticket_id BIGINT NOT NULL,
+    ci_key VARCHAR(200) NOT NULL,
+    ci_name VARCHAR(200) NOT NULL,
+    PRIMARY KEY (ticket_id, ci_key),
+    INDEX ticket_change_ci_key (ci_key),
+    CONSTRAINT FK_ticket_change_ci_ticket_id FOREIGN KEY (ticket_id) REFERENCES ticket_change_plan (ticket_id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -0,0 +1,19 @@
+-- Idempotency keys: response snapshots of mutating API requests, replayed
+-- when a client retries with the same Idempotency-Key
+
+CREATE TABLE IF NOT EXISTS api_idempotency_key (
+    id BIGINT NOT NULL AUTO_INCREMENT,
+    principal VARCHAR(100) NOT NULL,
+    idem_key VARCHAR(255) NOT NULL,
+    request_hash VARCHAR(64) NOT NULL,
+    method VARCHAR(10) NOT NULL,
+    path VARCHAR(500) NOT NULL,
+    status_code INT NOT NULL DEFAULT 0,
+    content_type VARCHAR(255) NULL,
+    response_body MEDIUMTEXT NULL,
+    create_time DATETIME NOT NULL,
+    expires_time DATETIME NOT NULL,
+    PRIMARY KEY (id),
+    UNIQUE INDEX api_idempotency_key_principal_key (principal, idem_key),
+    INDEX api_idempotency_key_expires (expires_time)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -0,0 +1,3 @@
+-- Remove customer portal domains
+DROP TABLE IF EXISTS customer_portal_domain_queue;
+DROP TABLE IF EXISTS customer_portal_domain;
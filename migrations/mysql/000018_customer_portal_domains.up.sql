@@ -0,0 +1,38 @@
+-- Customer portal domains: custom host names that serve a branded portal
+-- for one customer company, with its own theme, identity provider and
+-- visible queues
+
+CREATE TABLE IF NOT EXISTS customer_portal_domain (
+    id INT NOT NULL AUTO_INCREMENT,
+    domain VARCHAR(253) NOT NULL,
+    customer_id VARCHAR(150) NOT NULL,
+    theme VARCHAR(100) NULL,
+    logo_url VARCHAR(500) NULL,
+    sso_provider_id INT NULL,
+    password_login SMALLINT NOT NULL DEFAULT 1,
+    cert_status VARCHAR(20) NOT NULL DEFAULT 'pending',
+    cert_message VARCHAR(500) NULL,
+    cert_change_time DATETIME NULL,
+    valid_id SMALLINT NOT NULL DEFAULT 1,
+    create_time DATETIME NOT NULL,
+    create_by INT NOT NULL,
+    change_time DATETIME NOT NULL,
+    change_by INT NOT NULL,
+    PRIMARY KEY (id),
+    UNIQUE KEY customer_portal_domain_domain (domain),
+    INDEX customer_portal_domain_customer (customer_id),
+    CONSTRAINT FK_customer_portal_domain_customer_id FOREIGN KEY (customer_id) REFERENCES customer_company (customer_id) ON DELETE CASCADE,
+    CONSTRAINT FK_customer_portal_domain_sso_provider_id FOREIGN KEY (sso_provider_id) REFERENCES sso_provider (id) ON DELETE SET NULL,
+    CONSTRAINT FK_customer_portal_domain_valid_id FOREIGN KEY (valid_id) REFERENCES valid (id),
+    CONSTRAINT FK_customer_portal_domain_create_by FOREIGN KEY (create_by) REFERENCES users (id),
+    CONSTRAINT FK_customer_portal_domain_change_by FOREIGN KEY (change_by) REFERENCES users (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS customer_portal_domain_queue (
+    domain_id INT NOT NULL,
+    queue_id INT NOT NULL,
+    position INT NOT NULL DEFAULT 0,
+    PRIMARY KEY (domain_id, queue_id),
+    CONSTRAINT FK_customer_portal_domain_queue_domain_id FOREIGN KEY (domain_id) REFERENCES customer_portal_domain (id) ON DELETE CASCADE,
+    CONSTRAINT FK_customer_portal_domain_queue_queue_id FOREIGN KEY (queue_id) REFERENCES queue (id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -0,0 +1,60 @@
+-- API request capture: admin-defined windows that record matching API
+-- requests (with secrets redacted) so they can be replayed against a
+-- staging instance or after a fix, and the responses compared
+
+CREATE TABLE IF NOT EXISTS api_request_capture (
+    id BIGINT NOT NULL AUTO_INCREMENT,
+    name VARCHAR(200) NOT NULL,
+    method VARCHAR(10) NULL,
+    path_prefix VARCHAR(500) NOT NULL,
+    principal VARCHAR(100) NULL,
+    max_requests INT NOT NULL,
+    expires_time DATETIME NOT NULL,
+    valid_id SMALLINT NOT NULL DEFAULT 1,
+    create_time DATETIME NOT NULL,
+    create_by INT NOT NULL,
+    change_time DATETIME NOT NULL,
+    change_by INT NOT NULL,
+    PRIMARY KEY (id),
+    CONSTRAINT FK_api_request_capture_valid_id FOREIGN KEY (valid_id) REFERENCES valid (id),
+    CONSTRAINT FK_api_request_capture_create_by FOREIGN KEY (create_by) REFERENCES users (id),
+    CONSTRAINT FK_api_request_capture_change_by FOREIGN KEY (change_by) REFERENCES users (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS api_captured_request (
+    id BIGINT NOT NULL AUTO_INCREMENT,
+    capture_id BIGINT NOT NULL,
+    principal VARCHAR(100) NOT NULL,
+    method VARCHAR(10) NOT NULL,
+    path VARCHAR(500) NOT NULL,
+    query_string TEXT NULL,
+    request_headers TEXT NULL,
+    request_body MEDIUMTEXT NULL,
+    status_code INT NOT NULL,
+    response_headers TEXT NULL,
+    response_body MEDIUMTEXT NULL,
+    duration_ms BIGINT NOT NULL DEFAULT 0,
+    truncated SMALLINT NOT NULL DEFAULT 0,
+    create_time DATETIME NOT NULL,
+    PRIMARY KEY (id),
+    INDEX api_captured_request_capture (capture_id),
+    INDEX api_captured_request_create_time (create_time),
+    CONSTRAINT FK_api_captured_request_capture_id FOREIGN KEY (capture_id) REFERENCES api_request_capture (id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS api_request_replay (
+    id BIGINT NOT NULL AUTO_INCREMENT,
+    captured_request_id BIGINT NOT NULL,
+    target VARCHAR(100) NOT NULL,
+    status_code INT NOT NULL DEFAULT 0,
+    response_body MEDIUMTEXT NULL,
+    diff MEDIUMTEXT NULL,
+    error_message VARCHAR(1000) NULL,
+    duration_ms BIGINT NOT NULL DEFAULT 0,
+    create_time DATETIME NOT NULL,
+    create_by INT NOT NULL,
+    PRIMARY KEY (id),
+    INDEX api_request_replay_request (captured_request_id),
+    CONSTRAINT FK_api_request_replay_captured_request_id FOREIGN KEY (captured_request_id) REFERENCES api_captured_request (id) ON DELETE CASCADE,
+    CONSTRAINT FK_api_request_replay_create_by FOREIGN KEY (create_by) REFERENCES users (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -0,0 +1,3 @@
+-- Remove customer company hierarchy
+DROP INDEX customer_company_parent ON customer_company;
+ALTER TABLE customer_company DROP COLUMN parent_customer_id;
@@ -0,0 +1,6 @@
+-- Customer company hierarchy: a company's group_customer permissions apply
+-- to the companies below it, and it sees their tickets
+-- (NULL = top-level company)
+ALTER TABLE customer_company ADD COLUMN parent_customer_id VARCHAR(150) NULL AFTER comments;
+
+CREATE INDEX customer_company_parent ON customer_company (parent_customer_id);
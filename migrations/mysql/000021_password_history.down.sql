@@ -0,0 +1,2 @@
+-- Remove password history
+DROP TABLE IF EXISTS password_history;
@@ -0,0 +1,12 @@
+-- Password history: the hashes of an account's recent passwords, so the
+-- password policy can refuse reuse and tell when a password has expired
+
+CREATE TABLE IF NOT EXISTS password_history (
+    id BIGINT NOT NULL AUTO_INCREMENT,
+    user_type VARCHAR(20) NOT NULL,
+    login VARCHAR(200) NOT NULL,
+    pw VARCHAR(128) NOT NULL,
+    create_time DATETIME NOT NULL,
+    PRIMARY KEY (id),
+    INDEX password_history_login (user_type, login)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
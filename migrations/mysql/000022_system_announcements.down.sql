@@ -0,0 +1,2 @@
+-- Remove system announcements
+DROP TABLE IF EXISTS system_announcement;
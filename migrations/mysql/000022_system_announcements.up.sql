@@ -0,0 +1,21 @@
+-- System announcements: ad-hoc banners shown to agents, customers or both,
+-- optionally limited to a time window
+
+CREATE TABLE IF NOT EXISTS system_announcement (
+    id INT NOT NULL AUTO_INCREMENT,
+    message VARCHAR(1000) NOT NULL,
+    audience VARCHAR(20) NOT NULL,
+    severity VARCHAR(20) NOT NULL,
+    start_time DATETIME NULL,
+    stop_time DATETIME NULL,
+    valid_id SMALLINT NOT NULL DEFAULT 1,
+    create_time DATETIME NOT NULL,
+    create_by INT NOT NULL,
+    change_time DATETIME NOT NULL,
+    change_by INT NOT NULL,
+    PRIMARY KEY (id),
+    INDEX system_announcement_valid (valid_id, start_time),
+    CONSTRAINT FK_system_announcement_valid_id FOREIGN KEY (valid_id) REFERENCES valid (id),
+    CONSTRAINT FK_system_announcement_create_by FOREIGN KEY (create_by) REFERENCES users (id),
+    CONSTRAINT FK_system_announcement_change_by FOREIGN KEY (change_by) REFERENCES users (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -0,0 +1,2 @@
+-- Remove the background job queue
+DROP TABLE IF EXISTS job_queue;
@@ -0,0 +1,21 @@
+-- Background job queue used by the database driver of internal/jobqueue.
+-- Completed jobs are deleted; failed ones stay until retried or deleted.
+
+CREATE TABLE IF NOT EXISTS job_queue (
+    id VARCHAR(32) NOT NULL,
+    queue VARCHAR(100) NOT NULL,
+    job_type VARCHAR(200) NOT NULL,
+    payload LONGTEXT NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    attempts INT NOT NULL DEFAULT 0,
+    max_attempts INT NOT NULL,
+    run_at DATETIME(3) NOT NULL,
+    locked_until DATETIME(3) NULL,
+    lock_token VARCHAR(32) NULL,
+    last_error TEXT NULL,
+    create_time DATETIME(3) NOT NULL,
+    change_time DATETIME(3) NOT NULL,
+    PRIMARY KEY (id),
+    INDEX job_queue_due (queue, status, run_at),
+    INDEX job_queue_status (status, change_time)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -0,0 +1,2 @@
+-- Remove the distributed lock table
+DROP TABLE IF EXISTS coordination_lock;
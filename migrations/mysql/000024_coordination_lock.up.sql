@@ -0,0 +1,11 @@
+-- Distributed locks and leader election used by internal/coordination.
+-- A row is a lock; expired rows are taken over by the next holder.
+
+CREATE TABLE IF NOT EXISTS coordination_lock (
+    name VARCHAR(200) NOT NULL,
+    holder VARCHAR(200) NOT NULL,
+    token VARCHAR(32) NOT NULL,
+    expires_at DATETIME(3) NOT NULL,
+    change_time DATETIME(3) NOT NULL,
+    PRIMARY KEY (name)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -0,0 +1,8 @@
+-- Remove ticket archiving. Restore archived tickets first: their article
+-- content is only kept in the archive tables.
+DROP TABLE IF EXISTS article_data_mime_plain_archive;
+DROP TABLE IF EXISTS article_data_mime_attachment_archive;
+DROP TABLE IF EXISTS article_data_mime_archive;
+DROP TABLE IF EXISTS ticket_archive;
+DROP TABLE IF EXISTS archive_run;
+DROP TABLE IF EXISTS archive_policy;
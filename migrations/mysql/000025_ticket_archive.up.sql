@@ -0,0 +1,108 @@
+-- Ticket archiving: policies that archive closed tickets some time after
+-- their last change, the runs that applied them, and archive tables that
+-- take the article content out of the live tables
+
+CREATE TABLE IF NOT EXISTS archive_policy (
+    id INT NOT NULL AUTO_INCREMENT,
+    name VARCHAR(200) NOT NULL,
+    state_types VARCHAR(200) NOT NULL DEFAULT 'closed',
+    queue_id INT NULL,
+    archive_after_days INT NOT NULL,
+    move_articles SMALLINT NOT NULL DEFAULT 0,
+    purge_after_days INT NOT NULL DEFAULT 0,
+    valid_id SMALLINT NOT NULL DEFAULT 1,
+    create_time DATETIME NOT NULL,
+    create_by INT NOT NULL,
+    change_time DATETIME NOT NULL,
+    change_by INT NOT NULL,
+    PRIMARY KEY (id),
+    UNIQUE KEY archive_policy_name (name),
+    CONSTRAINT FK_archive_policy_queue_id FOREIGN KEY (queue_id) REFERENCES queue (id),
+    CONSTRAINT FK_archive_policy_valid_id FOREIGN KEY (valid_id) REFERENCES valid (id),
+    CONSTRAINT FK_archive_policy_create_by FOREIGN KEY (create_by) REFERENCES users (id),
+    CONSTRAINT FK_archive_policy_change_by FOREIGN KEY (change_by) REFERENCES users (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS archive_run (
+    id INT NOT NULL AUTO_INCREMENT,
+    policy_id INT NULL,
+    dry_run SMALLINT NOT NULL DEFAULT 0,
+    status VARCHAR(20) NOT NULL,
+    tickets_archived INT NOT NULL DEFAULT 0,
+    articles_moved INT NOT NULL DEFAULT 0,
+    tickets_purged INT NOT NULL DEFAULT 0,
+    error_message VARCHAR(1000) NULL,
+    started_at DATETIME NOT NULL,
+    finished_at DATETIME NULL,
+    create_by INT NOT NULL,
+    PRIMARY KEY (id),
+    INDEX archive_run_started_at (started_at),
+    CONSTRAINT FK_archive_run_policy_id FOREIGN KEY (policy_id) REFERENCES archive_policy (id) ON DELETE SET NULL,
+    CONSTRAINT FK_archive_run_create_by FOREIGN KEY (create_by) REFERENCES users (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS ticket_archive (
+    ticket_id BIGINT NOT NULL,
+    policy_id INT NULL,
+    run_id INT NULL,
+    articles_moved INT NOT NULL DEFAULT 0,
+    archived_at DATETIME NOT NULL,
+    archived_by INT NOT NULL,
+    PRIMARY KEY (ticket_id),
+    INDEX ticket_archive_archived_at (archived_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS article_data_mime_archive (
+    id BIGINT NOT NULL,
+    article_id BIGINT NOT NULL,
+    a_from MEDIUMTEXT,
+    a_reply_to MEDIUMTEXT,
+    a_to MEDIUMTEXT,
+    a_cc MEDIUMTEXT,
+    a_bcc MEDIUMTEXT,
+    a_subject TEXT,
+    a_message_id TEXT,
+    a_message_id_md5 VARCHAR(32) DEFAULT NULL,
+    a_in_reply_to MEDIUMTEXT,
+    a_references MEDIUMTEXT,
+    a_content_type VARCHAR(250) DEFAULT NULL,
+    a_body MEDIUMTEXT,
+    incoming_time INT NOT NULL,
+    content_path VARCHAR(250) DEFAULT NULL,
+    create_time DATETIME NOT NULL,
+    create_by INT NOT NULL,
+    change_time DATETIME NOT NULL,
+    change_by INT NOT NULL,
+    PRIMARY KEY (id),
+    INDEX article_data_mime_archive_article_id (article_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS article_data_mime_attachment_archive (
+    id BIGINT NOT NULL,
+    article_id BIGINT NOT NULL,
+    filename VARCHAR(250) DEFAULT NULL,
+    content_size VARCHAR(30) DEFAULT NULL,
+    content_type TEXT,
+    content_id VARCHAR(250) DEFAULT NULL,
+    content_alternative VARCHAR(50) DEFAULT NULL,
+    disposition VARCHAR(15) DEFAULT NULL,
+    content LONGBLOB,
+    create_time DATETIME NOT NULL,
+    create_by INT NOT NULL,
+    change_time DATETIME NOT NULL,
+    change_by INT NOT NULL,
+    PRIMARY KEY (id),
+    INDEX article_data_mime_attachment_archive_article_id (article_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS article_data_mime_plain_archive (
+    id BIGINT NOT NULL,
+    article_id BIGINT NOT NULL,
+    body LONGBLOB NOT NULL,
+    create_time DATETIME NOT NULL,
+    create_by INT NOT NULL,
+    change_time DATETIME NOT NULL,
+    change_by INT NOT NULL,
+    PRIMARY KEY (id),
+    INDEX article_data_mime_plain_archive_article_id (article_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
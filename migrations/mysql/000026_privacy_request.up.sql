@@ -0,0 +1,50 @@
+-- Data subject requests (GDPR): exports of everything stored about a
+-- customer user and erasures that pseudonymize or erase it. Every request
+-- needs an admin's approval and runs as a background job.
+
+CREATE TABLE IF NOT EXISTS privacy_request (
+    id INT NOT NULL AUTO_INCREMENT,
+    kind VARCHAR(20) NOT NULL,
+    subject VARCHAR(200) NOT NULL,
+    customer_login VARCHAR(200) NOT NULL,
+    field_policy MEDIUMTEXT NULL,
+    reason VARCHAR(1000) NULL,
+    status VARCHAR(20) NOT NULL,
+    requested_by INT NOT NULL,
+    requested_at DATETIME NOT NULL,
+    decided_by INT NULL,
+    decided_at DATETIME NULL,
+    decision_comment VARCHAR(1000) NULL,
+    job_id VARCHAR(32) NULL,
+    started_at DATETIME NULL,
+    finished_at DATETIME NULL,
+    result MEDIUMTEXT NULL,
+    error_message VARCHAR(1000) NULL,
+    PRIMARY KEY (id),
+    INDEX privacy_request_status (status, requested_at),
+    INDEX privacy_request_customer_login (customer_login),
+    CONSTRAINT FK_privacy_request_requested_by FOREIGN KEY (requested_by) REFERENCES users (id),
+    CONSTRAINT FK_privacy_request_decided_by FOREIGN KEY (decided_by) REFERENCES users (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS privacy_export (
+    request_id INT NOT NULL,
+    filename VARCHAR(250) NOT NULL,
+    content_size BIGINT NOT NULL,
+    content LONGBLOB NOT NULL,
+    create_time DATETIME NOT NULL,
+    PRIMARY KEY (request_id),
+    CONSTRAINT FK_privacy_export_request_id FOREIGN KEY (request_id) REFERENCES privacy_request (id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS privacy_request_log (
+    id INT NOT NULL AUTO_INCREMENT,
+    request_id INT NOT NULL,
+    action VARCHAR(50) NOT NULL,
+    message VARCHAR(1000) NULL,
+    create_time DATETIME NOT NULL,
+    create_by INT NOT NULL,
+    PRIMARY KEY (id),
+    INDEX privacy_request_log_request_id (request_id),
+    CONSTRAINT FK_privacy_request_log_request_id FOREIGN KEY (request_id) REFERENCES privacy_request (id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
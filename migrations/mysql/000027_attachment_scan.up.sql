@@ -0,0 +1,37 @@
+-- Antivirus scanning of attachments. Every scanned attachment gets a scan
+-- record; infected attachments are never stored with their article but kept
+-- in quarantine, where admins can release or delete them.
+
+CREATE TABLE IF NOT EXISTS attachment_scan (
+    id BIGINT NOT NULL AUTO_INCREMENT,
+    article_id BIGINT NOT NULL,
+    filename VARCHAR(250) NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    signature VARCHAR(250) NULL,
+    scanner VARCHAR(50) NOT NULL,
+    source VARCHAR(20) NOT NULL,
+    scanned_at DATETIME NOT NULL,
+    PRIMARY KEY (id),
+    INDEX attachment_scan_article (article_id, filename)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS attachment_quarantine (
+    id BIGINT NOT NULL AUTO_INCREMENT,
+    ticket_id BIGINT NOT NULL,
+    article_id BIGINT NOT NULL,
+    filename VARCHAR(250) NOT NULL,
+    content_type VARCHAR(250) NULL,
+    content_size BIGINT NOT NULL,
+    content LONGBLOB NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    signature VARCHAR(250) NULL,
+    scanner VARCHAR(50) NOT NULL,
+    source VARCHAR(20) NOT NULL,
+    create_time DATETIME NOT NULL,
+    create_by INT NOT NULL,
+    released_at DATETIME NULL,
+    released_by INT NULL,
+    PRIMARY KEY (id),
+    INDEX attachment_quarantine_create_time (create_time),
+    INDEX attachment_quarantine_article_id (article_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -0,0 +1,4 @@
+-- Remove customer satisfaction surveys and their responses.
+DROP TABLE IF EXISTS survey_response;
+DROP TABLE IF EXISTS survey_request;
+DROP TABLE IF EXISTS survey;
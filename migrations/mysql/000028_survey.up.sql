@@ -0,0 +1,52 @@
+-- Customer satisfaction surveys. A survey is sent to the customer of every
+-- ticket it matches once the ticket has been closed for its send delay; the
+-- customer answers through a tokenized link without logging in.
+
+CREATE TABLE IF NOT EXISTS survey (
+    id INT NOT NULL AUTO_INCREMENT,
+    name VARCHAR(200) NOT NULL,
+    description VARCHAR(500) NULL,
+    questions TEXT NOT NULL,
+    queue_ids TEXT NULL,
+    send_delay_minutes INT NOT NULL DEFAULT 0,
+    link_valid_days INT NOT NULL DEFAULT 14,
+    email_subject VARCHAR(250) NOT NULL,
+    email_body TEXT NOT NULL,
+    valid_id SMALLINT NOT NULL DEFAULT 1,
+    create_time DATETIME NOT NULL,
+    create_by INT NOT NULL,
+    change_time DATETIME NOT NULL,
+    change_by INT NOT NULL,
+    PRIMARY KEY (id),
+    UNIQUE KEY survey_name (name),
+    CONSTRAINT FK_survey_create_by FOREIGN KEY (create_by) REFERENCES users (id),
+    CONSTRAINT FK_survey_change_by FOREIGN KEY (change_by) REFERENCES users (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS survey_request (
+    id BIGINT NOT NULL AUTO_INCREMENT,
+    survey_id INT NOT NULL,
+    ticket_id BIGINT NOT NULL,
+    token_hash VARCHAR(64) NOT NULL,
+    sent_at DATETIME NOT NULL,
+    expires_at DATETIME NOT NULL,
+    answered_at DATETIME NULL,
+    PRIMARY KEY (id),
+    UNIQUE KEY survey_request_token_hash (token_hash),
+    UNIQUE KEY survey_request_ticket_id (ticket_id),
+    INDEX survey_request_survey (survey_id, sent_at),
+    CONSTRAINT FK_survey_request_survey_id FOREIGN KEY (survey_id) REFERENCES survey (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS survey_response (
+    id BIGINT NOT NULL AUTO_INCREMENT,
+    request_id BIGINT NOT NULL,
+    question_id VARCHAR(50) NOT NULL,
+    question_type VARCHAR(20) NOT NULL,
+    rating INT NULL,
+    answer TEXT NULL,
+    create_time DATETIME NOT NULL,
+    PRIMARY KEY (id),
+    INDEX survey_response_request_id (request_id),
+    CONSTRAINT FK_survey_response_request_id FOREIGN KEY (request_id) REFERENCES survey_request (id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
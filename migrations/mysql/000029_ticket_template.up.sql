@@ -0,0 +1,44 @@
+-- Ticket templates (quick tickets): pre-filled title, body, queue, priority,
+-- type, state and dynamic field values agents pick when creating recurring
+-- request types.
+
+CREATE TABLE IF NOT EXISTS ticket_template (
+    id INT NOT NULL AUTO_INCREMENT,
+    name VARCHAR(200) NOT NULL,
+    description VARCHAR(500) NULL,
+    category VARCHAR(100) NULL,
+    title VARCHAR(255) NOT NULL,
+    body TEXT NOT NULL,
+    content_type VARCHAR(50) NOT NULL DEFAULT 'text/plain',
+    queue_id INT NULL,
+    priority_id SMALLINT NULL,
+    type_id SMALLINT NULL,
+    state_id SMALLINT NULL,
+    service_id INT NULL,
+    dynamic_fields TEXT NULL,
+    tags TEXT NULL,
+    usage_count INT NOT NULL DEFAULT 0,
+    valid_id SMALLINT NOT NULL DEFAULT 1,
+    create_time DATETIME NOT NULL,
+    create_by INT NOT NULL,
+    change_time DATETIME NOT NULL,
+    change_by INT NOT NULL,
+    PRIMARY KEY (id),
+    UNIQUE KEY ticket_template_name (name),
+    CONSTRAINT FK_ticket_template_queue_id FOREIGN KEY (queue_id) REFERENCES queue (id),
+    CONSTRAINT FK_ticket_template_priority_id FOREIGN KEY (priority_id) REFERENCES ticket_priority (id),
+    CONSTRAINT FK_ticket_template_type_id FOREIGN KEY (type_id) REFERENCES ticket_type (id),
+    CONSTRAINT FK_ticket_template_state_id FOREIGN KEY (state_id) REFERENCES ticket_state (id),
+    CONSTRAINT FK_ticket_template_service_id FOREIGN KEY (service_id) REFERENCES service (id),
+    CONSTRAINT FK_ticket_template_create_by FOREIGN KEY (create_by) REFERENCES users (id),
+    CONSTRAINT FK_ticket_template_change_by FOREIGN KEY (change_by) REFERENCES users (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS ticket_template_group (
+    template_id INT NOT NULL,
+    group_id INT NOT NULL,
+    PRIMARY KEY (template_id, group_id),
+    INDEX ticket_template_group_group_id (group_id),
+    CONSTRAINT FK_ticket_template_group_template_id FOREIGN KEY (template_id) REFERENCES ticket_template (id) ON DELETE CASCADE,
+    CONSTRAINT FK_ticket_template_group_group_id FOREIGN KEY (group_id) REFERENCES `groups` (id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
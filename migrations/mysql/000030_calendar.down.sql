@@ -0,0 +1,3 @@
+-- Remove calendar appointment attendees and ticket links.
+DROP TABLE IF EXISTS calendar_appointment_ticket_link;
+DROP TABLE IF EXISTS calendar_appointment_attendee;
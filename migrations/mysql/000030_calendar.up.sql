@@ -0,0 +1,24 @@
+-- Calendar appointments: the agents attending an appointment and the
+-- tickets it was linked to by hand. Appointments generated by ticket
+-- appointment rules keep using calendar_appointment_ticket.
+
+CREATE TABLE IF NOT EXISTS calendar_appointment_attendee (
+    appointment_id BIGINT NOT NULL,
+    user_id INT NOT NULL,
+    PRIMARY KEY (appointment_id, user_id),
+    INDEX calendar_appointment_attendee_user_id (user_id),
+    CONSTRAINT FK_calendar_appointment_attendee_appointment_id FOREIGN KEY (appointment_id) REFERENCES calendar_appointment (id) ON DELETE CASCADE,
+    CONSTRAINT FK_calendar_appointment_attendee_user_id FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS calendar_appointment_ticket_link (
+    appointment_id BIGINT NOT NULL,
+    ticket_id BIGINT NOT NULL,
+    create_time DATETIME NOT NULL,
+    create_by INT NOT NULL,
+    PRIMARY KEY (appointment_id, ticket_id),
+    INDEX calendar_appointment_ticket_link_ticket_id (ticket_id),
+    CONSTRAINT FK_calendar_appointment_ticket_link_appointment_id FOREIGN KEY (appointment_id) REFERENCES calendar_appointment (id) ON DELETE CASCADE,
+    CONSTRAINT FK_calendar_appointment_ticket_link_ticket_id FOREIGN KEY (ticket_id) REFERENCES ticket (id) ON DELETE CASCADE,
+    CONSTRAINT FK_calendar_appointment_ticket_link_create_by FOREIGN KEY (create_by) REFERENCES users (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
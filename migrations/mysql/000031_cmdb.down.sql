@@ -0,0 +1,4 @@
+-- Remove the CMDB tables.
+DROP TABLE IF EXISTS cmdb_ci_ticket;
+DROP TABLE IF EXISTS cmdb_ci;
+DROP TABLE IF EXISTS cmdb_class;
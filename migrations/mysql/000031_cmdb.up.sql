@@ -0,0 +1,55 @@
+-- CMDB: classes of configuration items with the attributes their items
+-- carry, the items with their lifecycle state and customer, and the tickets
+-- items are linked to.
+
+CREATE TABLE IF NOT EXISTS cmdb_class (
+    id INT NOT NULL AUTO_INCREMENT,
+    name VARCHAR(200) NOT NULL,
+    description VARCHAR(500) NULL,
+    attributes TEXT NULL,
+    valid_id SMALLINT NOT NULL DEFAULT 1,
+    create_time DATETIME NOT NULL,
+    create_by INT NOT NULL,
+    change_time DATETIME NOT NULL,
+    change_by INT NOT NULL,
+    PRIMARY KEY (id),
+    UNIQUE KEY cmdb_class_name (name),
+    CONSTRAINT FK_cmdb_class_create_by FOREIGN KEY (create_by) REFERENCES users (id),
+    CONSTRAINT FK_cmdb_class_change_by FOREIGN KEY (change_by) REFERENCES users (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS cmdb_ci (
+    id BIGINT NOT NULL AUTO_INCREMENT,
+    number VARCHAR(100) NOT NULL,
+    class_id INT NOT NULL,
+    name VARCHAR(250) NOT NULL,
+    state VARCHAR(50) NOT NULL,
+    customer_id VARCHAR(150) NULL,
+    customer_user_id VARCHAR(200) NULL,
+    attributes TEXT NULL,
+    create_time DATETIME NOT NULL,
+    create_by INT NOT NULL,
+    change_time DATETIME NOT NULL,
+    change_by INT NOT NULL,
+    PRIMARY KEY (id),
+    UNIQUE KEY cmdb_ci_number (number),
+    INDEX cmdb_ci_class_id (class_id),
+    INDEX cmdb_ci_name (name),
+    INDEX cmdb_ci_customer_id (customer_id),
+    INDEX cmdb_ci_customer_user_id (customer_user_id),
+    CONSTRAINT FK_cmdb_ci_class_id FOREIGN KEY (class_id) REFERENCES cmdb_class (id),
+    CONSTRAINT FK_cmdb_ci_create_by FOREIGN KEY (create_by) REFERENCES users (id),
+    CONSTRAINT FK_cmdb_ci_change_by FOREIGN KEY (change_by) REFERENCES users (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS cmdb_ci_ticket (
+    ci_id BIGINT NOT NULL,
+    ticket_id BIGINT NOT NULL,
+    create_time DATETIME NOT NULL,
+    create_by INT NOT NULL,
+    PRIMARY KEY (ci_id, ticket_id),
+    INDEX cmdb_ci_ticket_ticket_id (ticket_id),
+    CONSTRAINT FK_cmdb_ci_ticket_ci_id FOREIGN KEY (ci_id) REFERENCES cmdb_ci (id) ON DELETE CASCADE,
+    CONSTRAINT FK_cmdb_ci_ticket_ticket_id FOREIGN KEY (ticket_id) REFERENCES ticket (id) ON DELETE CASCADE,
+    CONSTRAINT FK_cmdb_ci_ticket_create_by FOREIGN KEY (create_by) REFERENCES users (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -0,0 +1,48 @@
+-- Priority matrix: the impact and urgency levels tickets are rated with,
+-- the matrix mapping each pair to a priority (queue_id 0 is the default
+-- matrix, other queues override single cells) and the rating of tickets.
+
+CREATE TABLE IF NOT EXISTS priority_matrix_level (
+    id INT NOT NULL AUTO_INCREMENT,
+    kind VARCHAR(20) NOT NULL,
+    name VARCHAR(200) NOT NULL,
+    position INT NOT NULL DEFAULT 0,
+    valid_id SMALLINT NOT NULL DEFAULT 1,
+    create_time DATETIME NOT NULL,
+    create_by INT NOT NULL,
+    change_time DATETIME NOT NULL,
+    change_by INT NOT NULL,
+    PRIMARY KEY (id),
+    UNIQUE KEY priority_matrix_level_kind_name (kind, name),
+    CONSTRAINT FK_priority_matrix_level_create_by FOREIGN KEY (create_by) REFERENCES users (id),
+    CONSTRAINT FK_priority_matrix_level_change_by FOREIGN KEY (change_by) REFERENCES users (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS priority_matrix_cell (
+    queue_id INT NOT NULL DEFAULT 0,
+    impact_id INT NOT NULL,
+    urgency_id INT NOT NULL,
+    priority_id SMALLINT NOT NULL,
+    change_time DATETIME NOT NULL,
+    change_by INT NOT NULL,
+    PRIMARY KEY (queue_id, impact_id, urgency_id),
+    CONSTRAINT FK_priority_matrix_cell_impact_id FOREIGN KEY (impact_id) REFERENCES priority_matrix_level (id) ON DELETE CASCADE,
+    CONSTRAINT FK_priority_matrix_cell_urgency_id FOREIGN KEY (urgency_id) REFERENCES priority_matrix_level (id) ON DELETE CASCADE,
+    CONSTRAINT FK_priority_matrix_cell_priority_id FOREIGN KEY (priority_id) REFERENCES ticket_priority (id),
+    CONSTRAINT FK_priority_matrix_cell_change_by FOREIGN KEY (change_by) REFERENCES users (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS ticket_impact_urgency (
+    ticket_id BIGINT NOT NULL,
+    impact_id INT NULL,
+    urgency_id INT NULL,
+    change_time DATETIME NOT NULL,
+    change_by INT NOT NULL,
+    PRIMARY KEY (ticket_id),
+    INDEX ticket_impact_urgency_impact_id (impact_id),
+    INDEX ticket_impact_urgency_urgency_id (urgency_id),
+    CONSTRAINT FK_ticket_impact_urgency_ticket_id FOREIGN KEY (ticket_id) REFERENCES ticket (id) ON DELETE CASCADE,
+    CONSTRAINT FK_ticket_impact_urgency_impact_id FOREIGN KEY (impact_id) REFERENCES priority_matrix_level (id),
+    CONSTRAINT FK_ticket_impact_urgency_urgency_id FOREIGN KEY (urgency_id) REFERENCES priority_matrix_level (id),
+    CONSTRAINT FK_ticket_impact_urgency_change_by FOREIGN KEY (change_by) REFERENCES users (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
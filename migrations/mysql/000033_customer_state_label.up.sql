@@ -0,0 +1,17 @@
+-- Customer-facing ticket state labels: what the customer portal and
+-- customer API tokens show instead of the internal state name. States
+-- without a row fall back to a label derived from their state type.
+
+CREATE TABLE IF NOT EXISTS customer_state_label (
+    state_id SMALLINT NOT NULL,
+    label VARCHAR(200) NOT NULL,
+    i18n_key VARCHAR(200) NULL,
+    create_time DATETIME NOT NULL,
+    create_by INT NOT NULL,
+    change_time DATETIME NOT NULL,
+    change_by INT NOT NULL,
+    PRIMARY KEY (state_id),
+    CONSTRAINT FK_customer_state_label_state_id FOREIGN KEY (state_id) REFERENCES ticket_state (id) ON DELETE CASCADE,
+    CONSTRAINT FK_customer_state_label_create_by FOREIGN KEY (create_by) REFERENCES users (id),
+    CONSTRAINT FK_customer_state_label_change_by FOREIGN KEY (change_by) REFERENCES users (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -0,0 +1,29 @@
+-- Article drafts: in-progress replies, one per agent and ticket, saved as
+-- the agent types. base_article_id is the newest article the author had
+-- seen; later articles by others are reported as a conflict. Shared drafts
+-- can be read and edited by the other agents of the ticket.
+
+CREATE TABLE IF NOT EXISTS article_draft (
+    id BIGINT NOT NULL AUTO_INCREMENT,
+    ticket_id BIGINT NOT NULL,
+    user_id INT NOT NULL,
+    shared SMALLINT NOT NULL DEFAULT 0,
+    subject VARCHAR(3800) NULL,
+    body LONGTEXT NULL,
+    a_to MEDIUMTEXT NULL,
+    a_cc MEDIUMTEXT NULL,
+    a_bcc MEDIUMTEXT NULL,
+    content_type VARCHAR(250) NULL,
+    is_visible_for_customer SMALLINT NOT NULL DEFAULT 0,
+    base_article_id BIGINT NOT NULL DEFAULT 0,
+    version INT NOT NULL DEFAULT 1,
+    create_time DATETIME NOT NULL,
+    change_time DATETIME NOT NULL,
+    change_by INT NOT NULL,
+    PRIMARY KEY (id),
+    UNIQUE KEY article_draft_ticket_user (ticket_id, user_id),
+    INDEX article_draft_change_time (change_time),
+    CONSTRAINT FK_article_draft_ticket_id FOREIGN KEY (ticket_id) REFERENCES ticket (id) ON DELETE CASCADE,
+    CONSTRAINT FK_article_draft_user_id FOREIGN KEY (user_id) REFERENCES users (id),
+    CONSTRAINT FK_article_draft_change_by FOREIGN KEY (change_by) REFERENCES users (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
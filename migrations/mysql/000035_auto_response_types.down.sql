@@ -0,0 +1,2 @@
+-- The seeded types are kept: auto responses may still reference them.
+DROP INDEX queue_auto_response_queue_id ON queue_auto_response;
@@ -0,0 +1,11 @@
+-- Auto-response types, as in OTRS. Auto responses are assigned to queues
+-- per type (queue_auto_response), at most one per queue and type.
+
+INSERT IGNORE INTO auto_response_type (id, name, comments, valid_id, create_time, create_by, change_time, change_by) VALUES
+(1, 'auto reply', 'Automatic reply which will be sent out after a new ticket has been created.', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1),
+(2, 'auto reject', 'Automatic reject which will be sent out after a follow-up has been rejected.', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1),
+(3, 'auto follow up', 'Automatic confirmation which is sent out after a follow-up has been received for the ticket.', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1),
+(4, 'auto reply/new ticket', 'Automatic response which will be sent out after a follow-up has been rejected and a new ticket has been created.', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1),
+(5, 'auto remove', 'Auto remove will be sent out after a customer removed the request.', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1);
+
+CREATE INDEX queue_auto_response_queue_id ON queue_auto_response (queue_id);
@@ -0,0 +1,2 @@
+-- Remove the email bounce state.
+DROP TABLE IF EXISTS email_bounce;
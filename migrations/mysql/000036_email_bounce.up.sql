@@ -0,0 +1,17 @@
+-- Bounce state per email address, fed by the bounce reports (DSNs) the
+-- inbound mail pipeline receives. Auto responses to addresses with a hard
+-- bounce, or with too many recent soft bounces, are paused.
+
+CREATE TABLE IF NOT EXISTS email_bounce (
+    id INT NOT NULL AUTO_INCREMENT,
+    email VARCHAR(150) NOT NULL,
+    bounce_type VARCHAR(10) NOT NULL,
+    status VARCHAR(20) NULL,
+    diagnostic VARCHAR(500) NULL,
+    hard_count INT NOT NULL DEFAULT 0,
+    soft_count INT NOT NULL DEFAULT 0,
+    first_bounce_time DATETIME NOT NULL,
+    last_bounce_time DATETIME NOT NULL,
+    PRIMARY KEY (id),
+    UNIQUE KEY email_bounce_email (email)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -0,0 +1,2 @@
+-- Remove the branding assets.
+DROP TABLE IF EXISTS branding_asset;
@@ -0,0 +1,18 @@
+-- Logo and favicon of the branding, globally or per customer company
+-- (tenant). The remaining branding (colors, texts, email header and footer)
+-- is stored in sysconfig.
+
+CREATE TABLE IF NOT EXISTS branding_asset (
+    id INT NOT NULL AUTO_INCREMENT,
+    scope VARCHAR(150) NOT NULL,
+    kind VARCHAR(20) NOT NULL,
+    filename VARCHAR(250) NOT NULL,
+    content_type VARCHAR(100) NOT NULL,
+    content_size INT NOT NULL,
+    checksum VARCHAR(64) NOT NULL,
+    content LONGBLOB NOT NULL,
+    create_time DATETIME NOT NULL,
+    create_by INT NOT NULL,
+    PRIMARY KEY (id),
+    UNIQUE KEY branding_asset_scope_kind (scope, kind)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -0,0 +1,2 @@
+-- Remove the language packs.
+DROP TABLE IF EXISTS language_pack;
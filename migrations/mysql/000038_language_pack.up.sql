@@ -0,0 +1,15 @@
+-- Language packs imported at runtime. Each pack holds the translations of
+-- one language as flat JSON (dot-notation key -> text) and is layered over
+-- the built-in translation files; an import replaces the language's row.
+
+CREATE TABLE IF NOT EXISTS language_pack (
+    id INT NOT NULL AUTO_INCREMENT,
+    language VARCHAR(20) NOT NULL,
+    content LONGTEXT NOT NULL,
+    entry_count INT NOT NULL,
+    checksum VARCHAR(64) NOT NULL,
+    create_time DATETIME NOT NULL,
+    create_by INT NOT NULL,
+    PRIMARY KEY (id),
+    UNIQUE KEY language_pack_language (language)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
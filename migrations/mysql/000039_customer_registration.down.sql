@@ -0,0 +1,2 @@
+-- Remove the customer self-registrations.
+DROP TABLE IF EXISTS customer_registration;
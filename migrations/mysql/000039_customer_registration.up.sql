@@ -0,0 +1,26 @@
+-- Customer self-registrations from the customer portal. A registration
+-- waits for its email address to be verified, and with approval mode for
+-- an admin, before its customer_user account is created. Only a hash of
+-- the verification token is stored.
+
+CREATE TABLE IF NOT EXISTS customer_registration (
+    id BIGINT NOT NULL AUTO_INCREMENT,
+    email VARCHAR(150) NOT NULL,
+    first_name VARCHAR(100) NOT NULL,
+    last_name VARCHAR(100) NOT NULL,
+    customer_id VARCHAR(150) NOT NULL,
+    pw VARCHAR(128) NOT NULL,
+    token_hash VARCHAR(64) NULL,
+    status VARCHAR(20) NOT NULL,
+    remote_ip VARCHAR(64) NULL,
+    expires_at DATETIME NOT NULL,
+    verified_at DATETIME NULL,
+    decided_at DATETIME NULL,
+    decided_by INT NULL,
+    create_time DATETIME NOT NULL,
+    change_time DATETIME NOT NULL,
+    PRIMARY KEY (id),
+    UNIQUE KEY customer_registration_token_hash (token_hash),
+    INDEX customer_registration_email (email),
+    INDEX customer_registration_status (status, create_time)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -0,0 +1,26 @@
+-- Forgot-password requests of agents and customers. Every request is
+-- recorded, also for logins without an account, so they can be limited per
+-- account; only a keyed hash of the emailed reset token is stored. Accounts
+-- that request too many resets are locked out of them for a while.
+
+CREATE TABLE IF NOT EXISTS password_reset_request (
+    id BIGINT NOT NULL AUTO_INCREMENT,
+    user_type VARCHAR(20) NOT NULL,
+    login VARCHAR(200) NOT NULL,
+    token_hash VARCHAR(64) NULL,
+    remote_ip VARCHAR(64) NULL,
+    expires_at DATETIME NOT NULL,
+    used_at DATETIME NULL,
+    create_time DATETIME NOT NULL,
+    PRIMARY KEY (id),
+    UNIQUE KEY password_reset_request_token_hash (token_hash),
+    INDEX password_reset_request_login (user_type, login, create_time)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS password_reset_lockout (
+    user_type VARCHAR(20) NOT NULL,
+    login VARCHAR(200) NOT NULL,
+    locked_until DATETIME NOT NULL,
+    create_time DATETIME NOT NULL,
+    PRIMARY KEY (user_type, login)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
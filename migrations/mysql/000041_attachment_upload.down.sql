@@ -0,0 +1,3 @@
+-- Remove the chunked uploads.
+DROP TABLE IF EXISTS attachment_upload_chunk;
+DROP TABLE IF EXISTS attachment_upload;
@@ -0,0 +1,28 @@
+-- Chunked uploads of large attachments. An upload is started for a ticket
+-- with the file's name and size, its chunks are appended in order, and once
+-- all bytes arrived the file is assembled, checked and attached; then the
+-- upload and its chunks are removed. Abandoned uploads are removed after a
+-- day.
+
+CREATE TABLE IF NOT EXISTS attachment_upload (
+    id VARCHAR(64) NOT NULL,
+    ticket_id BIGINT NOT NULL,
+    article_id BIGINT NULL,
+    filename VARCHAR(250) NOT NULL,
+    content_type VARCHAR(250) NULL,
+    total_size BIGINT NOT NULL,
+    received_size BIGINT NOT NULL,
+    user_id INT NOT NULL,
+    user_role VARCHAR(20) NOT NULL,
+    create_time DATETIME NOT NULL,
+    change_time DATETIME NOT NULL,
+    PRIMARY KEY (id),
+    INDEX attachment_upload_change_time (change_time)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS attachment_upload_chunk (
+    upload_id VARCHAR(64) NOT NULL,
+    chunk_offset BIGINT NOT NULL,
+    content LONGBLOB NOT NULL,
+    PRIMARY KEY (upload_id, chunk_offset)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -0,0 +1,2 @@
+-- Remove the visible text of articles.
+DROP TABLE IF EXISTS article_visible_text;
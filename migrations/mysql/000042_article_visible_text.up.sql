@@ -0,0 +1,13 @@
+-- The part of inbound email articles the sender wrote, without the quoted
+-- earlier messages and signature, in the format of the article body. It is
+-- searched instead of the full body and shown collapsed; the full body
+-- stays in article_data_mime.
+
+CREATE TABLE IF NOT EXISTS article_visible_text (
+    article_id BIGINT NOT NULL,
+    visible_text LONGTEXT NOT NULL,
+    has_quote SMALLINT NOT NULL DEFAULT 0,
+    has_signature SMALLINT NOT NULL DEFAULT 0,
+    create_time DATETIME NOT NULL,
+    PRIMARY KEY (article_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -0,0 +1,2 @@
+-- Remove the daily queue statistics.
+DROP TABLE IF EXISTS queue_stats_daily;
@@ -0,0 +1,21 @@
+-- Daily ticket statistics per queue, written by the nightly queue-stats job
+-- so that dashboards read a small table instead of scanning tickets.
+-- Durations are stored as sums with their counts, so averages over any
+-- window are exact. Open and pending counts are a snapshot taken when the
+-- day was aggregated; they are NULL for days aggregated later.
+
+CREATE TABLE IF NOT EXISTS queue_stats_daily (
+    queue_id INT NOT NULL,
+    stat_date DATE NOT NULL,
+    open_count INT NULL,
+    pending_count INT NULL,
+    created_count INT NOT NULL DEFAULT 0,
+    closed_count INT NOT NULL DEFAULT 0,
+    first_response_count INT NOT NULL DEFAULT 0,
+    first_response_seconds BIGINT NOT NULL DEFAULT 0,
+    resolution_count INT NOT NULL DEFAULT 0,
+    resolution_seconds BIGINT NOT NULL DEFAULT 0,
+    create_time DATETIME NOT NULL,
+    PRIMARY KEY (queue_id, stat_date),
+    INDEX queue_stats_daily_stat_date (stat_date)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -0,0 +1,22 @@
+-- State of `gk import otrs`: the GoatFlow ID each imported OTRS/Znuny row
+-- got, so re-runs update instead of duplicating, and how far each entity
+-- was imported, so interrupted imports resume.
+
+CREATE TABLE IF NOT EXISTS otrs_import_map (
+    source VARCHAR(100) NOT NULL,
+    entity VARCHAR(50) NOT NULL,
+    source_id BIGINT NOT NULL,
+    target_id BIGINT NOT NULL,
+    created SMALLINT NOT NULL DEFAULT 0,
+    imported_at DATETIME NOT NULL,
+    PRIMARY KEY (source, entity, source_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS otrs_import_state (
+    source VARCHAR(100) NOT NULL,
+    entity VARCHAR(50) NOT NULL,
+    last_id BIGINT NOT NULL DEFAULT 0,
+    watermark VARCHAR(40) NULL,
+    updated_at DATETIME NOT NULL,
+    PRIMARY KEY (source, entity)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
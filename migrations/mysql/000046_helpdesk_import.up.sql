@@ -0,0 +1,35 @@
+-- Imports from hosted helpdesks (Zendesk, Freshdesk): one row per run for
+-- the admin progress page, and the GoatFlow row each imported record went
+-- to, so re-runs add what is new instead of duplicating.
+
+CREATE TABLE IF NOT EXISTS helpdesk_import_run (
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    source VARCHAR(20) NOT NULL,
+    account VARCHAR(200) NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    phase VARCHAR(30) NOT NULL,
+    organizations INT NOT NULL DEFAULT 0,
+    users INT NOT NULL DEFAULT 0,
+    tickets INT NOT NULL DEFAULT 0,
+    articles INT NOT NULL DEFAULT 0,
+    attachments INT NOT NULL DEFAULT 0,
+    skipped INT NOT NULL DEFAULT 0,
+    issues TEXT,
+    last_error TEXT,
+    since DATETIME NULL,
+    started_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL,
+    finished_at DATETIME NULL,
+    INDEX idx_helpdesk_import_run_source (source, account, status)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS helpdesk_import_map (
+    source VARCHAR(20) NOT NULL,
+    account VARCHAR(200) NOT NULL,
+    entity VARCHAR(30) NOT NULL,
+    external_id VARCHAR(100) NOT NULL,
+    target_id BIGINT NOT NULL DEFAULT 0,
+    target_key VARCHAR(200) NULL,
+    imported_at DATETIME NOT NULL,
+    PRIMARY KEY (source, account, entity, external_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
@@ -0,0 +1,13 @@
+-- Plugin log entries kept beyond the in-memory buffer, for the per-plugin
+-- log history. The plugin log retention job removes old entries.
+
+CREATE TABLE IF NOT EXISTS plugin_log (
+    id BIGINT AUTO_INCREMENT PRIMARY KEY,
+    plugin VARCHAR(200) NOT NULL,
+    level VARCHAR(10) NOT NULL,
+    message TEXT NOT NULL,
+    fields TEXT,
+    create_time DATETIME NOT NULL,
+    INDEX idx_plugin_log_plugin_time (plugin, create_time),
+    INDEX idx_plugin_log_time (create_time)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
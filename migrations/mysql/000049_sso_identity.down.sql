@@ -0,0 +1,2 @@
+-- Remove the single sign-on account links.
+DROP TABLE IF EXISTS sso_identity;
@@ -0,0 +1,17 @@
+-- Accounts linked to the identity a single sign-on provider asserts for
+-- them. Logins match the provider's stable subject (OIDC sub, SAML NameID),
+-- never a username or email claim the user may be able to change.
+
+CREATE TABLE IF NOT EXISTS sso_identity (
+    id INT NOT NULL AUTO_INCREMENT,
+    provider_id INT NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    user_id INT NOT NULL,
+    create_time DATETIME NOT NULL,
+    create_by INT NOT NULL,
+    PRIMARY KEY (id),
+    UNIQUE KEY sso_identity_subject (provider_id, subject),
+    INDEX sso_identity_user (provider_id, user_id),
+    CONSTRAINT FK_sso_identity_provider_id FOREIGN KEY (provider_id) REFERENCES sso_provider (id) ON DELETE CASCADE,
+    CONSTRAINT FK_sso_identity_create_by FOREIGN KEY (create_by) REFERENCES users (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
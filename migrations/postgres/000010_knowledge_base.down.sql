@@ -0,0 +1,4 @@
+-- Remove knowledge base tables
+DROP TABLE IF EXISTS kb_article_version;
+DROP TABLE IF EXISTS kb_article;
+DROP TABLE IF EXISTS kb_category;
@@ -0,0 +1,49 @@
+-- Knowledge base: categories, articles and article versions
+
+CREATE TABLE IF NOT EXISTS kb_category (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(200) NOT NULL,
+    parent_id INT REFERENCES kb_category(id),
+    comments VARCHAR(250),
+    valid_id SMALLINT NOT NULL DEFAULT 1 REFERENCES valid(id),
+    create_time TIMESTAMP NOT NULL,
+    create_by INT NOT NULL REFERENCES users(id),
+    change_time TIMESTAMP NOT NULL,
+    change_by INT NOT NULL REFERENCES users(id),
+    UNIQUE (name, parent_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_kb_category_parent_id ON kb_category(parent_id);
+
+CREATE TABLE IF NOT EXISTS kb_article (
+    id SERIAL PRIMARY KEY,
+    category_id INT NOT NULL REFERENCES kb_category(id),
+    title VARCHAR(255) NOT NULL,
+    body TEXT NOT NULL,
+    keywords VARCHAR(500),
+    visibility VARCHAR(20) NOT NULL DEFAULT 'internal', -- 'internal', 'customer', 'public'
+    version INT NOT NULL DEFAULT 1,                      -- Current version number
+    valid_id SMALLINT NOT NULL DEFAULT 1 REFERENCES valid(id),
+    create_time TIMESTAMP NOT NULL,
+    create_by INT NOT NULL REFERENCES users(id),
+    change_time TIMESTAMP NOT NULL,
+    change_by INT NOT NULL REFERENCES users(id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_kb_article_category_id ON kb_article(category_id);
+CREATE INDEX IF NOT EXISTS idx_kb_article_visibility ON kb_article(visibility, valid_id);
+
+-- Every saved revision of an article, including the current one
+CREATE TABLE IF NOT EXISTS kb_article_version (
+    id SERIAL PRIMARY KEY,
+    article_id INT NOT NULL REFERENCES kb_article(id) ON DELETE CASCADE,
+    version INT NOT NULL,
+    title VARCHAR(255) NOT NULL,
+    body TEXT NOT NULL,
+    keywords VARCHAR(500),
+    visibility VARCHAR(20) NOT NULL,
+    change_note VARCHAR(250),
+    create_time TIMESTAMP NOT NULL,
+    create_by INT NOT NULL REFERENCES users(id),
+    UNIQUE (article_id, version)
+);
@@ -0,0 +1,2 @@
+-- Remove plugin schema migration tracking
+DROP TABLE IF EXISTS plugin_schema_migration;
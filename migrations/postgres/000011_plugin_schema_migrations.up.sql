@@ -0,0 +1,8 @@
+-- Applied plugin schema migrations (one row per plugin and version)
+CREATE TABLE IF NOT EXISTS plugin_schema_migration (
+    plugin_name VARCHAR(200) NOT NULL,
+    version INT NOT NULL,
+    description VARCHAR(250),
+    applied_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (plugin_name, version)
+);
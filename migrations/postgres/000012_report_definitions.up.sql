@@ -0,0 +1,21 @@
+-- Report builder: saved report definitions with optional email schedule
+
+CREATE TABLE IF NOT EXISTS report_definition (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(200) NOT NULL,
+    description VARCHAR(500),
+    metric VARCHAR(50) NOT NULL,                    -- 'tickets_created', 'tickets_closed', 'avg_resolution_hours'
+    group_by VARCHAR(50) NOT NULL DEFAULT 'none',   -- 'none', 'queue', 'state', 'priority', 'owner', 'customer'
+    time_bucket VARCHAR(20) NOT NULL DEFAULT 'none', -- 'none', 'day', 'week', 'month'
+    filters TEXT,                                   -- JSON encoded models.ReportFilters
+    schedule VARCHAR(100),                          -- Cron expression; empty when not scheduled
+    schedule_format VARCHAR(20),                    -- 'table', 'csv', 'png'
+    recipient_group_ids VARCHAR(500),               -- JSON array of group IDs
+    last_sent_time TIMESTAMP NULL,
+    valid_id SMALLINT NOT NULL DEFAULT 1 REFERENCES valid(id),
+    create_time TIMESTAMP NOT NULL,
+    create_by INT NOT NULL REFERENCES users(id),
+    change_time TIMESTAMP NOT NULL,
+    change_by INT NOT NULL REFERENCES users(id),
+    UNIQUE (name)
+);
@@ -0,0 +1,37 @@
+-- Delegated approvals: rules that require a group's approval before a ticket
+-- action runs, and the approval requests raised under them
+
+CREATE TABLE IF NOT EXISTS ticket_approval_rule (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(200) NOT NULL,
+    queue_id INT REFERENCES queue(id),             -- NULL applies the rule to every queue
+    action VARCHAR(20) NOT NULL,                   -- 'close', 'move'
+    approver_group_id INT NOT NULL REFERENCES groups(id),
+    comments VARCHAR(250),
+    valid_id SMALLINT NOT NULL DEFAULT 1 REFERENCES valid(id),
+    create_time TIMESTAMP NOT NULL,
+    create_by INT NOT NULL REFERENCES users(id),
+    change_time TIMESTAMP NOT NULL,
+    change_by INT NOT NULL REFERENCES users(id),
+    UNIQUE (name)
+);
+
+CREATE INDEX IF NOT EXISTS ticket_approval_rule_queue_action ON ticket_approval_rule (queue_id, action);
+
+CREATE TABLE IF NOT EXISTS ticket_approval_request (
+    id BIGSERIAL PRIMARY KEY,
+    rule_id INT NOT NULL REFERENCES ticket_approval_rule(id),
+    ticket_id BIGINT NOT NULL REFERENCES ticket(id) ON DELETE CASCADE,
+    action VARCHAR(20) NOT NULL,
+    target_id INT NOT NULL,                        -- State ID for 'close', queue ID for 'move'
+    reason TEXT,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending', -- 'pending', 'approved', 'rejected', 'cancelled'
+    requested_by INT NOT NULL REFERENCES users(id),
+    requested_time TIMESTAMP NOT NULL,
+    decided_by INT REFERENCES users(id),
+    decided_time TIMESTAMP NULL,
+    decision_comment TEXT
+);
+
+CREATE INDEX IF NOT EXISTS ticket_approval_request_ticket ON ticket_approval_request (ticket_id, status);
+CREATE INDEX IF NOT EXISTS ticket_approval_request_status ON ticket_approval_request (status);
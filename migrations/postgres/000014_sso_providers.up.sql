@@ -0,0 +1,20 @@
+-- Single sign-on: OpenID Connect and SAML 2.0 identity providers for the
+-- agent and customer frontends
+
+CREATE TABLE IF NOT EXISTS sso_provider (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(200) NOT NULL,
+    protocol VARCHAR(20) NOT NULL,                 -- 'oidc', 'saml'
+    frontend VARCHAR(20) NOT NULL,                 -- 'agent', 'customer'
+    config TEXT NOT NULL,                          -- JSON: endpoints, credentials, claim names
+    group_mappings TEXT,                           -- JSON: IdP group value -> GoatFlow group and permission
+    jit_provisioning SMALLINT NOT NULL DEFAULT 0,  -- Create unknown users on first login
+    valid_id SMALLINT NOT NULL DEFAULT 1 REFERENCES valid(id),
+    create_time TIMESTAMP NOT NULL,
+    create_by INT NOT NULL REFERENCES users(id),
+    change_time TIMESTAMP NOT NULL,
+    change_by INT NOT NULL REFERENCES users(id),
+    UNIQUE (name)
+);
+
+CREATE INDEX IF NOT EXISTS sso_provider_frontend ON sso_provider (frontend, valid_id);
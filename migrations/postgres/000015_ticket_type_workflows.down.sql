@@ -0,0 +1,4 @@
+-- Remove ticket type workflows
+DROP TABLE IF EXISTS ticket_type_transition;
+DROP TABLE IF EXISTS ticket_type_dynamic_field;
+DROP TABLE IF EXISTS ticket_type_workflow;
@@ -0,0 +1,26 @@
+-- Ticket type workflows: per-type creation defaults, dynamic field sets and
+-- allowed state transitions
+
+CREATE TABLE IF NOT EXISTS ticket_type_workflow (
+    type_id SMALLINT PRIMARY KEY REFERENCES ticket_type(id) ON DELETE CASCADE,
+    default_queue_id INT REFERENCES queue(id),
+    default_priority_id SMALLINT REFERENCES ticket_priority(id),
+    default_sla_id INT REFERENCES sla(id),
+    change_time TIMESTAMP NOT NULL,
+    change_by INT NOT NULL REFERENCES users(id)
+);
+
+CREATE TABLE IF NOT EXISTS ticket_type_dynamic_field (
+    type_id SMALLINT NOT NULL REFERENCES ticket_type(id) ON DELETE CASCADE,
+    field_id INT NOT NULL REFERENCES dynamic_field(id) ON DELETE CASCADE,
+    required SMALLINT NOT NULL DEFAULT 0,          -- 1 makes the field mandatory on create
+    PRIMARY KEY (type_id, field_id)
+);
+
+-- A type without rows here allows every state change
+CREATE TABLE IF NOT EXISTS ticket_type_transition (
+    type_id SMALLINT NOT NULL REFERENCES ticket_type(id) ON DELETE CASCADE,
+    from_state_id SMALLINT NOT NULL REFERENCES ticket_state(id),
+    to_state_id SMALLINT NOT NULL REFERENCES ticket_state(id),
+    PRIMARY KEY (type_id, from_state_id, to_state_id)
+);
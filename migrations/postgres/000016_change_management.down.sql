@@ -0,0 +1,4 @@
+-- Remove change management
+DROP TABLE IF EXISTS ticket_change_ci;
+DROP TABLE IF EXISTS ticket_change_service;
+DROP TABLE IF EXISTS ticket_change_plan;
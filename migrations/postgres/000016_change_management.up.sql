@@ -0,0 +1,36 @@
+-- Change management: the planned window and scope of change tickets, used
+-- for the change calendar and collision warnings
+
+CREATE TABLE IF NOT EXISTS ticket_change_plan (
+    ticket_id BIGINT PRIMARY KEY REFERENCES ticket(id) ON DELETE CASCADE,
+    planned_start TIMESTAMP NOT NULL,
+    planned_end TIMESTAMP NOT NULL,
+    risk VARCHAR(20) NOT NULL DEFAULT 'medium',   -- 'low', 'medium', 'high', 'critical'
+    implementation_plan TEXT,
+    backout_plan TEXT,
+    create_time TIMESTAMP NOT NULL,
+    create_by INT NOT NULL REFERENCES users(id),
+    change_time TIMESTAMP NOT NULL,
+    change_by INT NOT NULL REFERENCES users(id)
+);
+
+CREATE INDEX IF NOT EXISTS ticket_change_plan_window ON ticket_change_plan (planned_start, planned_end);
+
+CREATE TABLE IF NOT EXISTS ticket_change_service (
+    ticket_id BIGINT NOT NULL REFERENCES ticket_change_plan(ticket_id) ON DELETE CASCADE,
+    service_id INT NOT NULL REFERENCES service(id) ON DELETE CASCADE,
+    PRIMARY KEY (ticket_id, service_id)
+);
+
+CREATE INDEX IF NOT EXISTS ticket_change_service_service ON ticket_change_service (service_id);
+
+-- Configuration items are identified by name (hostname, CI number, ...);
+-- ci_key is the lower-cased name used to match changes
+CREATE TABLE IF NOT EXISTS ticket_change_ci (
+    ticket_id BIGINT NOT NULL REFERENCES ticket_change_plan(ticket_id) ON DELETE CASCADE,
+    ci_key VARCHAR(200) NOT NULL,
+    ci_name VARCHAR(200) NOT NULL,
+    PRIMARY KEY (ticket_id, ci_key)
+);
+
+CREATE INDEX IF NOT EXISTS ticket_change_ci_key ON ticket_change_ci (ci_key);
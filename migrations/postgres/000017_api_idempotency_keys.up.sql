@@ -0,0 +1,19 @@
+-- Idempotency keys: response snapshots of mutating API requests, replayed
+-- when a client retries with the same Idempotency-Key
+
+CREATE TABLE IF NOT EXISTS api_idempotency_key (
+    id BIGSERIAL PRIMARY KEY,
+    principal VARCHAR(100) NOT NULL,   -- 'token:<id>', 'user:<id>' or 'customer:<id>'
+    idem_key VARCHAR(255) NOT NULL,
+    request_hash VARCHAR(64) NOT NULL, -- SHA-256 of method, path and body
+    method VARCHAR(10) NOT NULL,
+    path VARCHAR(500) NOT NULL,
+    status_code INT NOT NULL DEFAULT 0, -- 0 while the first request is still running
+    content_type VARCHAR(255),
+    response_body TEXT,
+    create_time TIMESTAMP NOT NULL,
+    expires_time TIMESTAMP NOT NULL,
+    CONSTRAINT api_idempotency_key_principal_key UNIQUE (principal, idem_key)
+);
+
+CREATE INDEX IF NOT EXISTS api_idempotency_key_expires ON api_idempotency_key (expires_time);
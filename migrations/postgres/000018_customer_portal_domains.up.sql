@@ -0,0 +1,33 @@
+-- Customer portal domains: custom host names that serve a branded portal
+-- for one customer company, with its own theme, identity provider and
+-- visible queues
+
+CREATE TABLE IF NOT EXISTS customer_portal_domain (
+    id SERIAL PRIMARY KEY,
+    domain VARCHAR(253) NOT NULL,                  -- Lowercase host name without port
+    customer_id VARCHAR(150) NOT NULL REFERENCES customer_company(customer_id) ON DELETE CASCADE,
+    theme VARCHAR(100),                            -- Theme ID; empty keeps the customer's choice
+    logo_url VARCHAR(500),
+    sso_provider_id INT REFERENCES sso_provider(id) ON DELETE SET NULL,
+    password_login SMALLINT NOT NULL DEFAULT 1,    -- 0 = sign in through sso_provider_id only
+    cert_status VARCHAR(20) NOT NULL DEFAULT 'pending', -- 'pending', 'requested', 'issued', 'failed'
+    cert_message VARCHAR(500),
+    cert_change_time TIMESTAMP,
+    valid_id SMALLINT NOT NULL DEFAULT 1 REFERENCES valid(id),
+    create_time TIMESTAMP NOT NULL,
+    create_by INT NOT NULL REFERENCES users(id),
+    change_time TIMESTAMP NOT NULL,
+    change_by INT NOT NULL REFERENCES users(id),
+    UNIQUE (domain)
+);
+
+CREATE INDEX IF NOT EXISTS customer_portal_domain_customer ON customer_portal_domain (customer_id);
+
+-- Queues whose tickets the portal shows; new tickets go to the first one.
+-- No rows means the portal is not restricted.
+CREATE TABLE IF NOT EXISTS customer_portal_domain_queue (
+    domain_id INT NOT NULL REFERENCES customer_portal_domain(id) ON DELETE CASCADE,
+    queue_id INT NOT NULL REFERENCES queue(id) ON DELETE CASCADE,
+    position INT NOT NULL DEFAULT 0,
+    PRIMARY KEY (domain_id, queue_id)
+);
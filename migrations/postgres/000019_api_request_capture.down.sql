@@ -0,0 +1,4 @@
+-- Remove API request capture and replay
+DROP TABLE IF EXISTS api_request_replay;
+DROP TABLE IF EXISTS api_captured_request;
+DROP TABLE IF EXISTS api_request_capture;
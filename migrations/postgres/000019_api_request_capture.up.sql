@@ -0,0 +1,53 @@
+-- API request capture: admin-defined windows that record matching API
+-- requests (with secrets redacted) so they can be replayed against a
+-- staging instance or after a fix, and the responses compared
+
+CREATE TABLE IF NOT EXISTS api_request_capture (
+    id BIGSERIAL PRIMARY KEY,
+    name VARCHAR(200) NOT NULL,
+    method VARCHAR(10),                    -- Empty captures every method
+    path_prefix VARCHAR(500) NOT NULL,     -- e.g. '/api/v1/tickets'
+    principal VARCHAR(100),                -- 'token:<id>', 'user:<id>' or 'customer:<id>'; empty captures everyone
+    max_requests INT NOT NULL,
+    expires_time TIMESTAMP NOT NULL,
+    valid_id SMALLINT NOT NULL DEFAULT 1 REFERENCES valid(id),
+    create_time TIMESTAMP NOT NULL,
+    create_by INT NOT NULL REFERENCES users(id),
+    change_time TIMESTAMP NOT NULL,
+    change_by INT NOT NULL REFERENCES users(id)
+);
+
+CREATE TABLE IF NOT EXISTS api_captured_request (
+    id BIGSERIAL PRIMARY KEY,
+    capture_id BIGINT NOT NULL REFERENCES api_request_capture(id) ON DELETE CASCADE,
+    principal VARCHAR(100) NOT NULL,
+    method VARCHAR(10) NOT NULL,
+    path VARCHAR(500) NOT NULL,
+    query_string TEXT,
+    request_headers TEXT,                  -- JSON object, secrets redacted
+    request_body TEXT,                     -- Secrets redacted, truncated to max_body_bytes
+    status_code INT NOT NULL,
+    response_headers TEXT,
+    response_body TEXT,
+    duration_ms BIGINT NOT NULL DEFAULT 0,
+    truncated SMALLINT NOT NULL DEFAULT 0, -- 1 when a body was cut or omitted
+    create_time TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS api_captured_request_capture ON api_captured_request (capture_id);
+CREATE INDEX IF NOT EXISTS api_captured_request_create_time ON api_captured_request (create_time);
+
+CREATE TABLE IF NOT EXISTS api_request_replay (
+    id BIGSERIAL PRIMARY KEY,
+    captured_request_id BIGINT NOT NULL REFERENCES api_captured_request(id) ON DELETE CASCADE,
+    target VARCHAR(100) NOT NULL,          -- Replay target name from server.request_capture.replay_targets
+    status_code INT NOT NULL DEFAULT 0,    -- 0 when the target could not be reached
+    response_body TEXT,
+    diff TEXT,                             -- JSON list of differences to the captured response
+    error_message VARCHAR(1000),
+    duration_ms BIGINT NOT NULL DEFAULT 0,
+    create_time TIMESTAMP NOT NULL,
+    create_by INT NOT NULL REFERENCES users(id)
+);
+
+CREATE INDEX IF NOT EXISTS api_request_replay_request ON api_request_replay (captured_request_id);
@@ -0,0 +1,3 @@
+-- Remove customer company hierarchy
+DROP INDEX IF EXISTS customer_company_parent;
+ALTER TABLE customer_company DROP COLUMN IF EXISTS parent_customer_id;
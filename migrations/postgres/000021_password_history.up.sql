@@ -0,0 +1,12 @@
+-- Password history: the hashes of an account's recent passwords, so the
+-- password policy can refuse reuse and tell when a password has expired
+
+CREATE TABLE IF NOT EXISTS password_history (
+    id BIGSERIAL PRIMARY KEY,
+    user_type VARCHAR(20) NOT NULL,        -- 'agent' (users.login) or 'customer' (customer_user.login)
+    login VARCHAR(200) NOT NULL,
+    pw VARCHAR(128) NOT NULL,
+    create_time TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS password_history_login ON password_history (user_type, login);
@@ -0,0 +1,18 @@
+-- System announcements: ad-hoc banners shown to agents, customers or both,
+-- optionally limited to a time window
+
+CREATE TABLE IF NOT EXISTS system_announcement (
+    id SERIAL PRIMARY KEY,
+    message VARCHAR(1000) NOT NULL,
+    audience VARCHAR(20) NOT NULL,         -- 'agent', 'customer' or 'all'
+    severity VARCHAR(20) NOT NULL,         -- 'info', 'warning' or 'critical'
+    start_time TIMESTAMP,                  -- Empty shows the banner at once
+    stop_time TIMESTAMP,                   -- Empty shows the banner until it is invalidated
+    valid_id SMALLINT NOT NULL DEFAULT 1 REFERENCES valid(id),
+    create_time TIMESTAMP NOT NULL,
+    create_by INT NOT NULL REFERENCES users(id),
+    change_time TIMESTAMP NOT NULL,
+    change_by INT NOT NULL REFERENCES users(id)
+);
+
+CREATE INDEX IF NOT EXISTS system_announcement_valid ON system_announcement (valid_id, start_time);
@@ -0,0 +1,21 @@
+-- Background job queue used by the database driver of internal/jobqueue.
+-- Completed jobs are deleted; failed ones stay until retried or deleted.
+
+CREATE TABLE IF NOT EXISTS job_queue (
+    id VARCHAR(32) PRIMARY KEY,
+    queue VARCHAR(100) NOT NULL,
+    job_type VARCHAR(200) NOT NULL,
+    payload TEXT NOT NULL,                 -- JSON
+    status VARCHAR(20) NOT NULL,           -- 'pending', 'running' or 'failed'
+    attempts INT NOT NULL DEFAULT 0,
+    max_attempts INT NOT NULL,
+    run_at TIMESTAMP NOT NULL,             -- Not run before this
+    locked_until TIMESTAMP,                -- Lease of a running job
+    lock_token VARCHAR(32),
+    last_error TEXT,
+    create_time TIMESTAMP NOT NULL,
+    change_time TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS job_queue_due ON job_queue (queue, status, run_at);
+CREATE INDEX IF NOT EXISTS job_queue_status ON job_queue (status, change_time);
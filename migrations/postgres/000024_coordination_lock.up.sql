@@ -0,0 +1,10 @@
+-- Distributed locks and leader election used by internal/coordination.
+-- A row is a lock; expired rows are taken over by the next holder.
+
+CREATE TABLE IF NOT EXISTS coordination_lock (
+    name VARCHAR(200) PRIMARY KEY,
+    holder VARCHAR(200) NOT NULL,          -- Instance holding the lock
+    token VARCHAR(32) NOT NULL,            -- Identifies the current lease
+    expires_at TIMESTAMP NOT NULL,
+    change_time TIMESTAMP NOT NULL
+);
@@ -0,0 +1,104 @@
+-- Ticket archiving: policies that archive closed tickets some time after
+-- their last change, the runs that applied them, and archive tables that
+-- take the article content out of the live tables
+
+CREATE TABLE IF NOT EXISTS archive_policy (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(200) NOT NULL UNIQUE,
+    state_types VARCHAR(200) NOT NULL DEFAULT 'closed',  -- Comma separated ticket state type names
+    queue_id INT REFERENCES queue(id),                   -- Empty applies to every queue
+    archive_after_days INT NOT NULL,                     -- Days since the ticket last changed
+    move_articles SMALLINT NOT NULL DEFAULT 0,           -- Move the article content to the archive tables
+    purge_after_days INT NOT NULL DEFAULT 0,             -- Days after archiving to delete the ticket, 0 never
+    valid_id SMALLINT NOT NULL DEFAULT 1 REFERENCES valid(id),
+    create_time TIMESTAMP NOT NULL,
+    create_by INT NOT NULL REFERENCES users(id),
+    change_time TIMESTAMP NOT NULL,
+    change_by INT NOT NULL REFERENCES users(id)
+);
+
+CREATE TABLE IF NOT EXISTS archive_run (
+    id SERIAL PRIMARY KEY,
+    policy_id INT REFERENCES archive_policy(id) ON DELETE SET NULL,  -- Empty for runs of every policy
+    dry_run SMALLINT NOT NULL DEFAULT 0,
+    status VARCHAR(20) NOT NULL,           -- 'running', 'success' or 'failed'
+    tickets_archived INT NOT NULL DEFAULT 0,
+    articles_moved INT NOT NULL DEFAULT 0,
+    tickets_purged INT NOT NULL DEFAULT 0,
+    error_message VARCHAR(1000),
+    started_at TIMESTAMP NOT NULL,
+    finished_at TIMESTAMP,
+    create_by INT NOT NULL REFERENCES users(id)
+);
+
+CREATE INDEX IF NOT EXISTS archive_run_started_at ON archive_run (started_at);
+
+-- One row per archived ticket. No foreign keys: purging removes the ticket
+-- and this row together.
+CREATE TABLE IF NOT EXISTS ticket_archive (
+    ticket_id BIGINT PRIMARY KEY,
+    policy_id INT,                         -- Empty when archived by hand
+    run_id INT,
+    articles_moved INT NOT NULL DEFAULT 0,
+    archived_at TIMESTAMP NOT NULL,
+    archived_by INT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS ticket_archive_archived_at ON ticket_archive (archived_at);
+
+-- Archived article content keeps its original IDs so a restore puts it
+-- back unchanged
+CREATE TABLE IF NOT EXISTS article_data_mime_archive (
+    id BIGINT PRIMARY KEY,
+    article_id BIGINT NOT NULL,
+    a_from TEXT,
+    a_reply_to TEXT,
+    a_to TEXT,
+    a_cc TEXT,
+    a_bcc TEXT,
+    a_subject TEXT,
+    a_message_id TEXT,
+    a_message_id_md5 VARCHAR(32) DEFAULT NULL,
+    a_in_reply_to TEXT,
+    a_references TEXT,
+    a_content_type VARCHAR(250) DEFAULT NULL,
+    a_body TEXT,
+    incoming_time INTEGER NOT NULL,
+    content_path VARCHAR(250) DEFAULT NULL,
+    create_time TIMESTAMP NOT NULL,
+    create_by INTEGER NOT NULL,
+    change_time TIMESTAMP NOT NULL,
+    change_by INTEGER NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS article_data_mime_archive_article_id ON article_data_mime_archive (article_id);
+
+CREATE TABLE IF NOT EXISTS article_data_mime_attachment_archive (
+    id BIGINT PRIMARY KEY,
+    article_id BIGINT NOT NULL,
+    filename VARCHAR(250) DEFAULT NULL,
+    content_size VARCHAR(30) DEFAULT NULL,
+    content_type TEXT,
+    content_id VARCHAR(250) DEFAULT NULL,
+    content_alternative VARCHAR(50) DEFAULT NULL,
+    disposition VARCHAR(15) DEFAULT NULL,
+    content BYTEA,
+    create_time TIMESTAMP NOT NULL,
+    create_by INTEGER NOT NULL,
+    change_time TIMESTAMP NOT NULL,
+    change_by INTEGER NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS article_data_mime_attachment_archive_article_id ON article_data_mime_attachment_archive (article_id);
+
+CREATE TABLE IF NOT EXISTS article_data_mime_plain_archive (
+    id BIGINT PRIMARY KEY,
+    article_id BIGINT NOT NULL,
+    body BYTEA NOT NULL,
+    create_time TIMESTAMP NOT NULL,
+    create_by INTEGER NOT NULL,
+    change_time TIMESTAMP NOT NULL,
+    change_by INTEGER NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS article_data_mime_plain_archive_article_id ON article_data_mime_plain_archive (article_id);
@@ -0,0 +1,4 @@
+-- Remove data subject requests, their exports and audit trail.
+DROP TABLE IF EXISTS privacy_request_log;
+DROP TABLE IF EXISTS privacy_export;
+DROP TABLE IF EXISTS privacy_request;
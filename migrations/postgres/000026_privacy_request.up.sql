@@ -0,0 +1,47 @@
+-- Data subject requests (GDPR): exports of everything stored about a
+-- customer user and erasures that pseudonymize or erase it. Every request
+-- needs an admin's approval and runs as a background job.
+
+CREATE TABLE IF NOT EXISTS privacy_request (
+    id SERIAL PRIMARY KEY,
+    kind VARCHAR(20) NOT NULL,             -- 'export' or 'erasure'
+    subject VARCHAR(200) NOT NULL,         -- Login or email as requested
+    customer_login VARCHAR(200) NOT NULL,  -- Resolved customer user login
+    field_policy TEXT,                     -- JSON field => keep/pseudonymize/erase (erasures)
+    reason VARCHAR(1000),
+    status VARCHAR(20) NOT NULL,           -- 'pending', 'approved', 'rejected', 'running', 'completed' or 'failed'
+    requested_by INT NOT NULL REFERENCES users(id),
+    requested_at TIMESTAMP NOT NULL,
+    decided_by INT REFERENCES users(id),
+    decided_at TIMESTAMP,
+    decision_comment VARCHAR(1000),
+    job_id VARCHAR(32),
+    started_at TIMESTAMP,
+    finished_at TIMESTAMP,
+    result TEXT,                           -- JSON counts of what was exported or changed
+    error_message VARCHAR(1000)
+);
+
+CREATE INDEX IF NOT EXISTS privacy_request_status ON privacy_request (status, requested_at);
+CREATE INDEX IF NOT EXISTS privacy_request_customer_login ON privacy_request (customer_login);
+
+-- The ZIP of a completed export.
+CREATE TABLE IF NOT EXISTS privacy_export (
+    request_id INT PRIMARY KEY REFERENCES privacy_request(id) ON DELETE CASCADE,
+    filename VARCHAR(250) NOT NULL,
+    content_size BIGINT NOT NULL,
+    content BYTEA NOT NULL,
+    create_time TIMESTAMP NOT NULL
+);
+
+-- Audit trail of each request: creation, decision, execution, downloads.
+CREATE TABLE IF NOT EXISTS privacy_request_log (
+    id SERIAL PRIMARY KEY,
+    request_id INT NOT NULL REFERENCES privacy_request(id) ON DELETE CASCADE,
+    action VARCHAR(50) NOT NULL,
+    message VARCHAR(1000),
+    create_time TIMESTAMP NOT NULL,
+    create_by INT NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS privacy_request_log_request_id ON privacy_request_log (request_id);
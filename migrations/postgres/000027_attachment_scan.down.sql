@@ -0,0 +1,3 @@
+-- Remove attachment scan records and the quarantine.
+DROP TABLE IF EXISTS attachment_quarantine;
+DROP TABLE IF EXISTS attachment_scan;
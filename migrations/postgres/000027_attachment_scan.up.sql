@@ -0,0 +1,37 @@
+-- Antivirus scanning of attachments. Every scanned attachment gets a scan
+-- record; infected attachments are never stored with their article but kept
+-- in quarantine, where admins can release or delete them.
+
+CREATE TABLE IF NOT EXISTS attachment_scan (
+    id BIGSERIAL PRIMARY KEY,
+    article_id BIGINT NOT NULL,
+    filename VARCHAR(250) NOT NULL,
+    status VARCHAR(20) NOT NULL,       -- 'clean', 'infected', 'error' or 'released'
+    signature VARCHAR(250),            -- Malware found, or the scanner error
+    scanner VARCHAR(50) NOT NULL,
+    source VARCHAR(20) NOT NULL,       -- 'upload' or 'email'
+    scanned_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS attachment_scan_article ON attachment_scan (article_id, filename);
+
+CREATE TABLE IF NOT EXISTS attachment_quarantine (
+    id BIGSERIAL PRIMARY KEY,
+    ticket_id BIGINT NOT NULL,
+    article_id BIGINT NOT NULL,
+    filename VARCHAR(250) NOT NULL,
+    content_type VARCHAR(250),
+    content_size BIGINT NOT NULL,
+    content BYTEA NOT NULL,
+    status VARCHAR(20) NOT NULL,       -- Scan status: 'infected' or 'error'
+    signature VARCHAR(250),
+    scanner VARCHAR(50) NOT NULL,
+    source VARCHAR(20) NOT NULL,
+    create_time TIMESTAMP NOT NULL,
+    create_by INT NOT NULL,
+    released_at TIMESTAMP,
+    released_by INT
+);
+
+CREATE INDEX IF NOT EXISTS attachment_quarantine_create_time ON attachment_quarantine (create_time);
+CREATE INDEX IF NOT EXISTS attachment_quarantine_article_id ON attachment_quarantine (article_id);
@@ -0,0 +1,50 @@
+-- Customer satisfaction surveys. A survey is sent to the customer of every
+-- ticket it matches once the ticket has been closed for its send delay; the
+-- customer answers through a tokenized link without logging in.
+
+CREATE TABLE IF NOT EXISTS survey (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(200) NOT NULL,
+    description VARCHAR(500),
+    questions TEXT NOT NULL,                   -- JSON list of questions
+    queue_ids TEXT,                            -- JSON list; empty means every queue
+    send_delay_minutes INT NOT NULL DEFAULT 0,
+    link_valid_days INT NOT NULL DEFAULT 14,
+    email_subject VARCHAR(250) NOT NULL,
+    email_body TEXT NOT NULL,                  -- With {{link}}, {{ticket_number}} and {{title}} placeholders
+    valid_id SMALLINT NOT NULL DEFAULT 1,
+    create_time TIMESTAMP NOT NULL,
+    create_by INT NOT NULL REFERENCES users(id),
+    change_time TIMESTAMP NOT NULL,
+    change_by INT NOT NULL REFERENCES users(id)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS survey_name ON survey (name);
+
+-- One survey link per ticket. Only a hash of the link token is stored.
+CREATE TABLE IF NOT EXISTS survey_request (
+    id BIGSERIAL PRIMARY KEY,
+    survey_id INT NOT NULL REFERENCES survey(id),
+    ticket_id BIGINT NOT NULL,
+    token_hash VARCHAR(64) NOT NULL,           -- Hex SHA-256 of the link token
+    sent_at TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP NOT NULL,
+    answered_at TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS survey_request_token_hash ON survey_request (token_hash);
+CREATE UNIQUE INDEX IF NOT EXISTS survey_request_ticket_id ON survey_request (ticket_id);
+CREATE INDEX IF NOT EXISTS survey_request_survey ON survey_request (survey_id, sent_at);
+
+-- The answers of a submitted survey, one row per question.
+CREATE TABLE IF NOT EXISTS survey_response (
+    id BIGSERIAL PRIMARY KEY,
+    request_id BIGINT NOT NULL REFERENCES survey_request(id) ON DELETE CASCADE,
+    question_id VARCHAR(50) NOT NULL,
+    question_type VARCHAR(20) NOT NULL,        -- 'rating', 'nps', 'choice' or 'text'
+    rating INT,                                -- Rating and NPS answers
+    answer TEXT,                               -- Choice and text answers
+    create_time TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS survey_response_request_id ON survey_response (request_id);
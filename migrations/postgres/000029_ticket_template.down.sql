@@ -0,0 +1,3 @@
+-- Remove ticket templates.
+DROP TABLE IF EXISTS ticket_template_group;
+DROP TABLE IF EXISTS ticket_template;
@@ -0,0 +1,38 @@
+-- Ticket templates (quick tickets): pre-filled title, body, queue, priority,
+-- type, state and dynamic field values agents pick when creating recurring
+-- request types.
+
+CREATE TABLE IF NOT EXISTS ticket_template (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(200) NOT NULL,
+    description VARCHAR(500),
+    category VARCHAR(100),
+    title VARCHAR(255) NOT NULL,
+    body TEXT NOT NULL,
+    content_type VARCHAR(50) NOT NULL DEFAULT 'text/plain',
+    queue_id INT REFERENCES queue(id),
+    priority_id INT REFERENCES ticket_priority(id),
+    type_id INT REFERENCES ticket_type(id),
+    state_id INT REFERENCES ticket_state(id),
+    service_id INT REFERENCES service(id),
+    dynamic_fields TEXT,                       -- JSON object of field name to value
+    tags TEXT,                                 -- JSON list
+    usage_count INT NOT NULL DEFAULT 0,
+    valid_id SMALLINT NOT NULL DEFAULT 1,
+    create_time TIMESTAMP NOT NULL,
+    create_by INT NOT NULL REFERENCES users(id),
+    change_time TIMESTAMP NOT NULL,
+    change_by INT NOT NULL REFERENCES users(id)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS ticket_template_name ON ticket_template (name);
+
+-- The groups whose members may use a template. A template without groups
+-- is available to every agent.
+CREATE TABLE IF NOT EXISTS ticket_template_group (
+    template_id INT NOT NULL REFERENCES ticket_template(id) ON DELETE CASCADE,
+    group_id INT NOT NULL REFERENCES groups(id) ON DELETE CASCADE,
+    PRIMARY KEY (template_id, group_id)
+);
+
+CREATE INDEX IF NOT EXISTS ticket_template_group_group_id ON ticket_template_group (group_id);
@@ -0,0 +1,21 @@
+-- Calendar appointments: the agents attending an appointment and the
+-- tickets it was linked to by hand. Appointments generated by ticket
+-- appointment rules keep using calendar_appointment_ticket.
+
+CREATE TABLE IF NOT EXISTS calendar_appointment_attendee (
+    appointment_id BIGINT NOT NULL REFERENCES calendar_appointment(id) ON DELETE CASCADE,
+    user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    PRIMARY KEY (appointment_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS calendar_appointment_attendee_user_id ON calendar_appointment_attendee (user_id);
+
+CREATE TABLE IF NOT EXISTS calendar_appointment_ticket_link (
+    appointment_id BIGINT NOT NULL REFERENCES calendar_appointment(id) ON DELETE CASCADE,
+    ticket_id BIGINT NOT NULL REFERENCES ticket(id) ON DELETE CASCADE,
+    create_time TIMESTAMP NOT NULL,
+    create_by INT NOT NULL REFERENCES users(id),
+    PRIMARY KEY (appointment_id, ticket_id)
+);
+
+CREATE INDEX IF NOT EXISTS calendar_appointment_ticket_link_ticket_id ON calendar_appointment_ticket_link (ticket_id);
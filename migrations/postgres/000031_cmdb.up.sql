@@ -0,0 +1,48 @@
+-- CMDB: classes of configuration items with the attributes their items
+-- carry, the items with their lifecycle state and customer, and the tickets
+-- items are linked to.
+
+CREATE TABLE IF NOT EXISTS cmdb_class (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(200) NOT NULL,
+    description VARCHAR(500),
+    attributes TEXT,                           -- JSON list of attribute definitions
+    valid_id SMALLINT NOT NULL DEFAULT 1,
+    create_time TIMESTAMP NOT NULL,
+    create_by INT NOT NULL REFERENCES users(id),
+    change_time TIMESTAMP NOT NULL,
+    change_by INT NOT NULL REFERENCES users(id)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS cmdb_class_name ON cmdb_class (name);
+
+CREATE TABLE IF NOT EXISTS cmdb_ci (
+    id BIGSERIAL PRIMARY KEY,
+    number VARCHAR(100) NOT NULL,
+    class_id INT NOT NULL REFERENCES cmdb_class(id),
+    name VARCHAR(250) NOT NULL,
+    state VARCHAR(50) NOT NULL,
+    customer_id VARCHAR(150),                  -- customer_company.customer_id
+    customer_user_id VARCHAR(200),             -- customer_user.login
+    attributes TEXT,                           -- JSON object of attribute values
+    create_time TIMESTAMP NOT NULL,
+    create_by INT NOT NULL REFERENCES users(id),
+    change_time TIMESTAMP NOT NULL,
+    change_by INT NOT NULL REFERENCES users(id)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS cmdb_ci_number ON cmdb_ci (number);
+CREATE INDEX IF NOT EXISTS cmdb_ci_class_id ON cmdb_ci (class_id);
+CREATE INDEX IF NOT EXISTS cmdb_ci_name ON cmdb_ci (name);
+CREATE INDEX IF NOT EXISTS cmdb_ci_customer_id ON cmdb_ci (customer_id);
+CREATE INDEX IF NOT EXISTS cmdb_ci_customer_user_id ON cmdb_ci (customer_user_id);
+
+CREATE TABLE IF NOT EXISTS cmdb_ci_ticket (
+    ci_id BIGINT NOT NULL REFERENCES cmdb_ci(id) ON DELETE CASCADE,
+    ticket_id BIGINT NOT NULL REFERENCES ticket(id) ON DELETE CASCADE,
+    create_time TIMESTAMP NOT NULL,
+    create_by INT NOT NULL REFERENCES users(id),
+    PRIMARY KEY (ci_id, ticket_id)
+);
+
+CREATE INDEX IF NOT EXISTS cmdb_ci_ticket_ticket_id ON cmdb_ci_ticket (ticket_id);
@@ -0,0 +1,4 @@
+-- Remove the priority matrix tables.
+DROP TABLE IF EXISTS ticket_impact_urgency;
+DROP TABLE IF EXISTS priority_matrix_cell;
+DROP TABLE IF EXISTS priority_matrix_level;
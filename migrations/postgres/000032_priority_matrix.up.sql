@@ -0,0 +1,38 @@
+-- Priority matrix: the impact and urgency levels tickets are rated with,
+-- the matrix mapping each pair to a priority (queue_id 0 is the default
+-- matrix, other queues override single cells) and the rating of tickets.
+
+CREATE TABLE IF NOT EXISTS priority_matrix_level (
+    id SERIAL PRIMARY KEY,
+    kind VARCHAR(20) NOT NULL,                 -- impact or urgency
+    name VARCHAR(200) NOT NULL,
+    position INT NOT NULL DEFAULT 0,
+    valid_id SMALLINT NOT NULL DEFAULT 1,
+    create_time TIMESTAMP NOT NULL,
+    create_by INT NOT NULL REFERENCES users(id),
+    change_time TIMESTAMP NOT NULL,
+    change_by INT NOT NULL REFERENCES users(id)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS priority_matrix_level_kind_name ON priority_matrix_level (kind, name);
+
+CREATE TABLE IF NOT EXISTS priority_matrix_cell (
+    queue_id INT NOT NULL DEFAULT 0,           -- 0 for the default matrix
+    impact_id INT NOT NULL REFERENCES priority_matrix_level(id) ON DELETE CASCADE,
+    urgency_id INT NOT NULL REFERENCES priority_matrix_level(id) ON DELETE CASCADE,
+    priority_id SMALLINT NOT NULL REFERENCES ticket_priority(id),
+    change_time TIMESTAMP NOT NULL,
+    change_by INT NOT NULL REFERENCES users(id),
+    PRIMARY KEY (queue_id, impact_id, urgency_id)
+);
+
+CREATE TABLE IF NOT EXISTS ticket_impact_urgency (
+    ticket_id BIGINT NOT NULL PRIMARY KEY REFERENCES ticket(id) ON DELETE CASCADE,
+    impact_id INT REFERENCES priority_matrix_level(id),
+    urgency_id INT REFERENCES priority_matrix_level(id),
+    change_time TIMESTAMP NOT NULL,
+    change_by INT NOT NULL REFERENCES users(id)
+);
+
+CREATE INDEX IF NOT EXISTS ticket_impact_urgency_impact_id ON ticket_impact_urgency (impact_id);
+CREATE INDEX IF NOT EXISTS ticket_impact_urgency_urgency_id ON ticket_impact_urgency (urgency_id);
@@ -0,0 +1,2 @@
+-- Remove the customer-facing ticket state labels.
+DROP TABLE IF EXISTS customer_state_label;
@@ -0,0 +1,13 @@
+-- Customer-facing ticket state labels: what the customer portal and
+-- customer API tokens show instead of the internal state name. States
+-- without a row fall back to a label derived from their state type.
+
+CREATE TABLE IF NOT EXISTS customer_state_label (
+    state_id SMALLINT NOT NULL PRIMARY KEY REFERENCES ticket_state(id) ON DELETE CASCADE,
+    label VARCHAR(200) NOT NULL,
+    i18n_key VARCHAR(200),                     -- translated in place of label when set
+    create_time TIMESTAMP NOT NULL,
+    create_by INT NOT NULL REFERENCES users(id),
+    change_time TIMESTAMP NOT NULL,
+    change_by INT NOT NULL REFERENCES users(id)
+);
@@ -0,0 +1,2 @@
+-- Remove article drafts.
+DROP TABLE IF EXISTS article_draft;
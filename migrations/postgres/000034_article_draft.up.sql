@@ -0,0 +1,26 @@
+-- Article drafts: in-progress replies, one per agent and ticket, saved as
+-- the agent types. base_article_id is the newest article the author had
+-- seen; later articles by others are reported as a conflict. Shared drafts
+-- can be read and edited by the other agents of the ticket.
+
+CREATE TABLE IF NOT EXISTS article_draft (
+    id BIGSERIAL PRIMARY KEY,
+    ticket_id BIGINT NOT NULL REFERENCES ticket(id) ON DELETE CASCADE,
+    user_id INT NOT NULL REFERENCES users(id),
+    shared SMALLINT NOT NULL DEFAULT 0,
+    subject VARCHAR(3800),
+    body TEXT,
+    a_to TEXT,
+    a_cc TEXT,
+    a_bcc TEXT,
+    content_type VARCHAR(250),
+    is_visible_for_customer SMALLINT NOT NULL DEFAULT 0,
+    base_article_id BIGINT NOT NULL DEFAULT 0,
+    version INT NOT NULL DEFAULT 1,         -- bumped on every save, for optimistic locking
+    create_time TIMESTAMP NOT NULL,
+    change_time TIMESTAMP NOT NULL,
+    change_by INT NOT NULL REFERENCES users(id)
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS article_draft_ticket_user ON article_draft (ticket_id, user_id);
+CREATE INDEX IF NOT EXISTS article_draft_change_time ON article_draft (change_time);
@@ -0,0 +1,2 @@
+-- The seeded types are kept: auto responses may still reference them.
+DROP INDEX IF EXISTS queue_auto_response_queue_id;
@@ -0,0 +1,17 @@
+-- Bounce state per email address, fed by the bounce reports (DSNs) the
+-- inbound mail pipeline receives. Auto responses to addresses with a hard
+-- bounce, or with too many recent soft bounces, are paused.
+
+CREATE TABLE IF NOT EXISTS email_bounce (
+    id SERIAL PRIMARY KEY,
+    email VARCHAR(150) NOT NULL,            -- lower case
+    bounce_type VARCHAR(10) NOT NULL,       -- type of the last bounce: hard or soft
+    status VARCHAR(20),                     -- DSN status of the last bounce, e.g. 5.1.1
+    diagnostic VARCHAR(500),
+    hard_count INT NOT NULL DEFAULT 0,
+    soft_count INT NOT NULL DEFAULT 0,      -- soft bounces in the current window
+    first_bounce_time TIMESTAMP NOT NULL,
+    last_bounce_time TIMESTAMP NOT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS email_bounce_email ON email_bounce (email);
@@ -0,0 +1,18 @@
+-- Logo and favicon of the branding, globally or per customer company
+-- (tenant). The remaining branding (colors, texts, email header and footer)
+-- is stored in sysconfig.
+
+CREATE TABLE IF NOT EXISTS branding_asset (
+    id SERIAL PRIMARY KEY,
+    scope VARCHAR(150) NOT NULL,            -- '' for the global branding, else a customer ID
+    kind VARCHAR(20) NOT NULL,              -- 'logo' or 'favicon'
+    filename VARCHAR(250) NOT NULL,
+    content_type VARCHAR(100) NOT NULL,
+    content_size INT NOT NULL,
+    checksum VARCHAR(64) NOT NULL,          -- SHA-256 of content, busts browser caches
+    content BYTEA NOT NULL,
+    create_time TIMESTAMP NOT NULL,
+    create_by INT NOT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS branding_asset_scope_kind ON branding_asset (scope, kind);
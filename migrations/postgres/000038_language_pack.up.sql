@@ -0,0 +1,15 @@
+-- Language packs imported at runtime. Each pack holds the translations of
+-- one language as flat JSON (dot-notation key -> text) and is layered over
+-- the built-in translation files; an import replaces the language's row.
+
+CREATE TABLE IF NOT EXISTS language_pack (
+    id SERIAL PRIMARY KEY,
+    language VARCHAR(20) NOT NULL,          -- e.g. 'de' or 'pt-BR'
+    content TEXT NOT NULL,                  -- JSON object of key -> translation
+    entry_count INT NOT NULL,
+    checksum VARCHAR(64) NOT NULL,          -- SHA-256 of content, detects changes for hot reload
+    create_time TIMESTAMP NOT NULL,
+    create_by INT NOT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS language_pack_language ON language_pack (language);
@@ -0,0 +1,26 @@
+-- Customer self-registrations from the customer portal. A registration
+-- waits for its email address to be verified, and with approval mode for
+-- an admin, before its customer_user account is created. Only a hash of
+-- the verification token is stored.
+
+CREATE TABLE IF NOT EXISTS customer_registration (
+    id BIGSERIAL PRIMARY KEY,
+    email VARCHAR(150) NOT NULL,            -- Also the login of the account
+    first_name VARCHAR(100) NOT NULL,
+    last_name VARCHAR(100) NOT NULL,
+    customer_id VARCHAR(150) NOT NULL,      -- From the domain mappings
+    pw VARCHAR(128) NOT NULL,               -- Password hash, copied to customer_user
+    token_hash VARCHAR(64),                 -- Hex SHA-256 of the link token; cleared once used
+    status VARCHAR(20) NOT NULL,            -- 'unverified', 'pending', 'approved' or 'rejected'
+    remote_ip VARCHAR(64),
+    expires_at TIMESTAMP NOT NULL,
+    verified_at TIMESTAMP,
+    decided_at TIMESTAMP,
+    decided_by INT,
+    create_time TIMESTAMP NOT NULL,
+    change_time TIMESTAMP NOT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS customer_registration_token_hash ON customer_registration (token_hash);
+CREATE INDEX IF NOT EXISTS customer_registration_email ON customer_registration (email);
+CREATE INDEX IF NOT EXISTS customer_registration_status ON customer_registration (status, create_time);
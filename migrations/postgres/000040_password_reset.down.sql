@@ -0,0 +1,3 @@
+-- Remove the forgot-password requests and lockouts.
+DROP TABLE IF EXISTS password_reset_lockout;
+DROP TABLE IF EXISTS password_reset_request;
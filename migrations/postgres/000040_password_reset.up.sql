@@ -0,0 +1,26 @@
+-- Forgot-password requests of agents and customers. Every request is
+-- recorded, also for logins without an account, so they can be limited per
+-- account; only a keyed hash of the emailed reset token is stored. Accounts
+-- that request too many resets are locked out of them for a while.
+
+CREATE TABLE IF NOT EXISTS password_reset_request (
+    id BIGSERIAL PRIMARY KEY,
+    user_type VARCHAR(20) NOT NULL,         -- 'agent' or 'customer'
+    login VARCHAR(200) NOT NULL,            -- Account login, or the lowercased name asked for
+    token_hash VARCHAR(64),                 -- Hex HMAC-SHA256 of the link token; cleared once used or replaced
+    remote_ip VARCHAR(64),
+    expires_at TIMESTAMP NOT NULL,
+    used_at TIMESTAMP,
+    create_time TIMESTAMP NOT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS password_reset_request_token_hash ON password_reset_request (token_hash);
+CREATE INDEX IF NOT EXISTS password_reset_request_login ON password_reset_request (user_type, login, create_time);
+
+CREATE TABLE IF NOT EXISTS password_reset_lockout (
+    user_type VARCHAR(20) NOT NULL,
+    login VARCHAR(200) NOT NULL,
+    locked_until TIMESTAMP NOT NULL,
+    create_time TIMESTAMP NOT NULL,
+    PRIMARY KEY (user_type, login)
+);
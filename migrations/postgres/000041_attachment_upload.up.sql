@@ -0,0 +1,28 @@
+-- Chunked uploads of large attachments. An upload is started for a ticket
+-- with the file's name and size, its chunks are appended in order, and once
+-- all bytes arrived the file is assembled, checked and attached; then the
+-- upload and its chunks are removed. Abandoned uploads are removed after a
+-- day.
+
+CREATE TABLE IF NOT EXISTS attachment_upload (
+    id VARCHAR(64) PRIMARY KEY,             -- Random upload ID, used in the upload URL
+    ticket_id BIGINT NOT NULL,
+    article_id BIGINT,                      -- Article to attach to; the latest one when empty
+    filename VARCHAR(250) NOT NULL,
+    content_type VARCHAR(250),
+    total_size BIGINT NOT NULL,
+    received_size BIGINT NOT NULL,
+    user_id INT NOT NULL,
+    user_role VARCHAR(20) NOT NULL,         -- Role whose attachment policy applies
+    create_time TIMESTAMP NOT NULL,
+    change_time TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS attachment_upload_change_time ON attachment_upload (change_time);
+
+CREATE TABLE IF NOT EXISTS attachment_upload_chunk (
+    upload_id VARCHAR(64) NOT NULL,
+    chunk_offset BIGINT NOT NULL,           -- Byte offset of the chunk in the file
+    content BYTEA NOT NULL,
+    PRIMARY KEY (upload_id, chunk_offset)
+);
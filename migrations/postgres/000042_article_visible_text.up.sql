@@ -0,0 +1,12 @@
+-- The part of inbound email articles the sender wrote, without the quoted
+-- earlier messages and signature, in the format of the article body. It is
+-- searched instead of the full body and shown collapsed; the full body
+-- stays in article_data_mime.
+
+CREATE TABLE IF NOT EXISTS article_visible_text (
+    article_id BIGINT PRIMARY KEY,
+    visible_text TEXT NOT NULL,
+    has_quote SMALLINT NOT NULL DEFAULT 0,      -- 1 when quoted messages were removed
+    has_signature SMALLINT NOT NULL DEFAULT 0,  -- 1 when a signature was removed
+    create_time TIMESTAMP NOT NULL
+);
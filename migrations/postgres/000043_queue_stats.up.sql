@@ -0,0 +1,22 @@
+-- Daily ticket statistics per queue, written by the nightly queue-stats job
+-- so that dashboards read a small table instead of scanning tickets.
+-- Durations are stored as sums with their counts, so averages over any
+-- window are exact. Open and pending counts are a snapshot taken when the
+-- day was aggregated; they are NULL for days aggregated later.
+
+CREATE TABLE IF NOT EXISTS queue_stats_daily (
+    queue_id INTEGER NOT NULL,
+    stat_date DATE NOT NULL,
+    open_count INTEGER,                             -- Tickets in new or open states
+    pending_count INTEGER,                          -- Tickets in pending states
+    created_count INTEGER NOT NULL DEFAULT 0,
+    closed_count INTEGER NOT NULL DEFAULT 0,
+    first_response_count INTEGER NOT NULL DEFAULT 0, -- Tickets first answered by an agent that day
+    first_response_seconds BIGINT NOT NULL DEFAULT 0,
+    resolution_count INTEGER NOT NULL DEFAULT 0,     -- Tickets closed that day
+    resolution_seconds BIGINT NOT NULL DEFAULT 0,
+    create_time TIMESTAMP NOT NULL,
+    PRIMARY KEY (queue_id, stat_date)
+);
+
+CREATE INDEX IF NOT EXISTS queue_stats_daily_stat_date ON queue_stats_daily (stat_date);
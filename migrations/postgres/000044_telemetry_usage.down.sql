@@ -0,0 +1,2 @@
+-- Remove the daily telemetry usage counts.
+DROP TABLE IF EXISTS telemetry_usage_daily;
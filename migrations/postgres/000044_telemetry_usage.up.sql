@@ -0,0 +1,13 @@
+-- Daily anonymous usage counted by opt-in telemetry: requests per route
+-- template and calls per plugin function, with their errors and summed
+-- durations. Each instance adds its counts to the day's rows.
+
+CREATE TABLE IF NOT EXISTS telemetry_usage_daily (
+    stat_date DATE NOT NULL,
+    kind VARCHAR(20) NOT NULL,        -- route or plugin
+    name VARCHAR(250) NOT NULL,       -- "GET /api/v1/tickets/:id" or "plugin.function"
+    calls BIGINT NOT NULL DEFAULT 0,
+    errors BIGINT NOT NULL DEFAULT 0, -- 5xx responses or failed plugin calls
+    duration_ms BIGINT NOT NULL DEFAULT 0,
+    PRIMARY KEY (stat_date, kind, name)
+);
@@ -0,0 +1,3 @@
+-- Remove the OTRS/Znuny import state.
+DROP TABLE IF EXISTS otrs_import_state;
+DROP TABLE IF EXISTS otrs_import_map;
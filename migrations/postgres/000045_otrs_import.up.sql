@@ -0,0 +1,22 @@
+-- State of `gk import otrs`: the GoatFlow ID each imported OTRS/Znuny row
+-- got, so re-runs update instead of duplicating, and how far each entity
+-- was imported, so interrupted imports resume.
+
+CREATE TABLE IF NOT EXISTS otrs_import_map (
+    source VARCHAR(100) NOT NULL,          -- name of the OTRS/Znuny system
+    entity VARCHAR(50) NOT NULL,           -- users, queues, tickets, ...
+    source_id BIGINT NOT NULL,
+    target_id BIGINT NOT NULL,
+    created SMALLINT NOT NULL DEFAULT 0,   -- 1 when the import created the row, 0 when it matched an existing one
+    imported_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (source, entity, source_id)
+);
+
+CREATE TABLE IF NOT EXISTS otrs_import_state (
+    source VARCHAR(100) NOT NULL,
+    entity VARCHAR(50) NOT NULL,
+    last_id BIGINT NOT NULL DEFAULT 0,     -- highest source ID processed
+    watermark VARCHAR(40) NULL,            -- source change_time up to which changes were imported
+    updated_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (source, entity)
+);
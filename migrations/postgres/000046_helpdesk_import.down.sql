@@ -0,0 +1,3 @@
+-- Remove the helpdesk import runs and ID map.
+DROP TABLE IF EXISTS helpdesk_import_map;
+DROP TABLE IF EXISTS helpdesk_import_run;
@@ -0,0 +1,36 @@
+-- Imports from hosted helpdesks (Zendesk, Freshdesk): one row per run for
+-- the admin progress page, and the GoatFlow row each imported record went
+-- to, so re-runs add what is new instead of duplicating.
+
+CREATE TABLE IF NOT EXISTS helpdesk_import_run (
+    id SERIAL PRIMARY KEY,
+    source VARCHAR(20) NOT NULL,           -- zendesk, freshdesk
+    account VARCHAR(200) NOT NULL,         -- subdomain or domain of the helpdesk
+    status VARCHAR(20) NOT NULL,           -- running, completed, failed
+    phase VARCHAR(30) NOT NULL,
+    organizations INTEGER NOT NULL DEFAULT 0,
+    users INTEGER NOT NULL DEFAULT 0,
+    tickets INTEGER NOT NULL DEFAULT 0,
+    articles INTEGER NOT NULL DEFAULT 0,
+    attachments INTEGER NOT NULL DEFAULT 0,
+    skipped INTEGER NOT NULL DEFAULT 0,
+    issues TEXT,                           -- JSON array of the first issues
+    last_error TEXT,
+    since TIMESTAMP NULL,                  -- only tickets updated after this were imported
+    started_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    finished_at TIMESTAMP NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_helpdesk_import_run_source ON helpdesk_import_run (source, account, status);
+
+CREATE TABLE IF NOT EXISTS helpdesk_import_map (
+    source VARCHAR(20) NOT NULL,
+    account VARCHAR(200) NOT NULL,
+    entity VARCHAR(30) NOT NULL,           -- organization, user, agent, ticket, comment
+    external_id VARCHAR(100) NOT NULL,
+    target_id BIGINT NOT NULL DEFAULT 0,   -- ticket, article or agent ID
+    target_key VARCHAR(200) NULL,          -- customer ID or customer login
+    imported_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (source, account, entity, external_id)
+);
@@ -0,0 +1,2 @@
+-- Remove the stored plugin log.
+DROP TABLE IF EXISTS plugin_log;
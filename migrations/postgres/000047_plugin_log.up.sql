@@ -0,0 +1,14 @@
+-- Plugin log entries kept beyond the in-memory buffer, for the per-plugin
+-- log history. The plugin log retention job removes old entries.
+
+CREATE TABLE IF NOT EXISTS plugin_log (
+    id BIGSERIAL PRIMARY KEY,
+    plugin VARCHAR(200) NOT NULL,
+    level VARCHAR(10) NOT NULL,            -- debug, info, warn, error
+    message TEXT NOT NULL,
+    fields TEXT,                           -- JSON object of structured fields
+    create_time TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_plugin_log_plugin_time ON plugin_log (plugin, create_time);
+CREATE INDEX IF NOT EXISTS idx_plugin_log_time ON plugin_log (create_time);
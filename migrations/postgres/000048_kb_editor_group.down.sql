@@ -0,0 +1,4 @@
+-- Remove the knowledge base editor group and its memberships.
+DELETE FROM group_user WHERE group_id IN (SELECT id FROM groups WHERE name = 'kb_editor');
+DELETE FROM group_role WHERE group_id IN (SELECT id FROM groups WHERE name = 'kb_editor');
+DELETE FROM groups WHERE name = 'kb_editor';
@@ -0,0 +1,14 @@
+-- Group whose members may create, update and delete knowledge base
+-- categories and articles besides admins.
+--
+-- The id is set explicitly above the seeded groups (1-3 in
+-- required_lookups.sql, 4-5 in the integration fixtures): lookups are
+-- seeded after migrations with ON CONFLICT (id) DO NOTHING, so taking
+-- id 1 here would keep the users group from being created.
+
+INSERT INTO groups (id, name, comments, valid_id, create_time, create_by, change_time, change_by)
+SELECT (SELECT CASE WHEN MAX(id) > 5 THEN MAX(id) ELSE 5 END + 1 FROM groups),
+       'kb_editor', 'Knowledge base editors', 1, CURRENT_TIMESTAMP, 1, CURRENT_TIMESTAMP, 1
+WHERE NOT EXISTS (SELECT 1 FROM groups WHERE name = 'kb_editor');
+
+SELECT setval('groups_id_seq', (SELECT MAX(id) FROM groups));
@@ -0,0 +1,15 @@
+-- Accounts linked to the identity a single sign-on provider asserts for
+-- them. Logins match the provider's stable subject (OIDC sub, SAML NameID),
+-- never a username or email claim the user may be able to change.
+
+CREATE TABLE IF NOT EXISTS sso_identity (
+    id SERIAL PRIMARY KEY,
+    provider_id INT NOT NULL REFERENCES sso_provider(id) ON DELETE CASCADE,
+    subject VARCHAR(255) NOT NULL,
+    user_id INT NOT NULL,                          -- users.id or customer_user.id, by the provider's frontend
+    create_time TIMESTAMP NOT NULL,
+    create_by INT NOT NULL REFERENCES users(id),
+    UNIQUE (provider_id, subject)
+);
+
+CREATE INDEX IF NOT EXISTS sso_identity_user ON sso_identity (provider_id, user_id);
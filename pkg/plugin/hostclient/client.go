@@ -0,0 +1,201 @@
+// Package hostclient is a typed client for the GoatFlow host API, for use by
+// gRPC plugins.
+//
+// A gRPC plugin reaches the host over the broker connection go-plugin opens
+// for callbacks. Plugins that implement SetHostClient receive a ready Client
+// before Init:
+//
+//	func (p *MyPlugin) SetHostClient(host *hostclient.Client) { p.host = host }
+//
+//	t, err := p.host.Tickets().Get(ctx, 42)
+//	err = p.host.Cache().Set(ctx, "key", data, time.Minute)
+//
+// Every call is bounded by a deadline, read-only calls are retried when the
+// host is slow or the connection hiccups, and host errors are mapped to the
+// typed errors in this package.
+package hostclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/rpc"
+	"time"
+)
+
+// Caller sends one host API call. The plugin runtime's broker client
+// implements it.
+type Caller interface {
+	CallContext(ctx context.Context, method string, args any) (json.RawMessage, error)
+}
+
+// Defaults applied by New.
+const (
+	DefaultTimeout = 10 * time.Second
+	DefaultRetries = 2
+	DefaultBackoff = 100 * time.Millisecond
+)
+
+// Client is a typed host API client. It is safe for concurrent use.
+type Client struct {
+	caller  Caller
+	timeout time.Duration
+	retries int
+	backoff time.Duration
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithTimeout bounds each attempt of a call. The caller's context deadline,
+// when sooner, still applies.
+func WithTimeout(d time.Duration) Option {
+	return func(c *Client) {
+		if d > 0 {
+			c.timeout = d
+		}
+	}
+}
+
+// WithRetries sets how many times a failed read-only call is retried.
+func WithRetries(n int) Option {
+	return func(c *Client) {
+		if n >= 0 {
+			c.retries = n
+		}
+	}
+}
+
+// WithBackoff sets the delay before the first retry; it doubles after each
+// further attempt.
+func WithBackoff(d time.Duration) Option {
+	return func(c *Client) {
+		if d > 0 {
+			c.backoff = d
+		}
+	}
+}
+
+// New creates a client that sends calls through caller.
+func New(caller Caller, opts ...Option) *Client {
+	c := &Client{
+		caller:  caller,
+		timeout: DefaultTimeout,
+		retries: DefaultRetries,
+		backoff: DefaultBackoff,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Tickets returns the ticket API.
+func (c *Client) Tickets() *TicketService {
+	return &TicketService{c: c}
+}
+
+// Cache returns the cache API.
+func (c *Client) Cache() *CacheService {
+	return &CacheService{c: c}
+}
+
+// Files returns the file storage API.
+func (c *Client) Files() *FileService {
+	return &FileService{c: c}
+}
+
+// HTTP returns the outbound HTTP API.
+func (c *Client) HTTP() *HTTPService {
+	return &HTTPService{c: c}
+}
+
+// Email returns the email API.
+func (c *Client) Email() *EmailService {
+	return &EmailService{c: c}
+}
+
+// CallPlugin calls fn in another plugin and decodes its result into out,
+// which may be nil. A missing or disabled plugin is reported as
+// *PluginNotFoundError or *PluginDisabledError.
+func (c *Client) CallPlugin(ctx context.Context, pluginName, fn string, args, out any) error {
+	raw, err := json.Marshal(args)
+	if err != nil {
+		return fmt.Errorf("hostclient: encode args: %w", err)
+	}
+	err = c.call(ctx, "plugin_call", map[string]any{
+		"plugin":   pluginName,
+		"function": fn,
+		"args":     json.RawMessage(raw),
+	}, out, false)
+	switch {
+	case errors.Is(err, errPluginNotFound):
+		return &PluginNotFoundError{Plugin: pluginName, Function: fn}
+	case errors.Is(err, errPluginDisabled):
+		return &PluginDisabledError{Plugin: pluginName}
+	}
+	return err
+}
+
+// call sends method and decodes the result into out, which may be nil.
+// Calls with retry set are retried on timeouts and transport failures; the
+// host reports its own errors only once, so those are never retried.
+func (c *Client) call(ctx context.Context, method string, args, out any, retry bool) error {
+	attempts := 1
+	if retry {
+		attempts += c.retries
+	}
+
+	var err error
+	delay := c.backoff
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return fmt.Errorf("hostclient: %s: %w", method, ctx.Err())
+			case <-time.After(delay):
+			}
+			delay *= 2
+		}
+
+		var result json.RawMessage
+		result, err = c.attempt(ctx, method, args)
+		if err == nil {
+			if out == nil || len(result) == 0 {
+				return nil
+			}
+			if err := json.Unmarshal(result, out); err != nil {
+				return fmt.Errorf("hostclient: %s: decode result: %w", method, err)
+			}
+			return nil
+		}
+		if !retryable(ctx, err) {
+			break
+		}
+	}
+	return mapError(method, err)
+}
+
+func (c *Client) attempt(ctx context.Context, method string, args any) (json.RawMessage, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	return c.caller.CallContext(ctx, method, args)
+}
+
+// retryable reports whether another attempt could succeed: the attempt timed
+// out while the caller's context is still live, or the transport failed.
+func retryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	var coded codedError
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return true
+	case errors.As(err, &coded), errors.Is(err, rpc.ErrShutdown), errors.Is(err, context.Canceled):
+		return false
+	}
+	var serverErr rpc.ServerError
+	return !errors.As(err, &serverErr)
+}
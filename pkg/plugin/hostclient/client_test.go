@@ -0,0 +1,198 @@
+package hostclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/rpc"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeCaller answers calls from a function and records them.
+type fakeCaller struct {
+	mu    sync.Mutex
+	calls []string
+	fn    func(ctx context.Context, method string, args any) (json.RawMessage, error)
+}
+
+func (f *fakeCaller) CallContext(ctx context.Context, method string, args any) (json.RawMessage, error) {
+	f.mu.Lock()
+	f.calls = append(f.calls, method)
+	f.mu.Unlock()
+	return f.fn(ctx, method, args)
+}
+
+func (f *fakeCaller) count() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.calls)
+}
+
+// codedErr stands in for the runtime's HostError.
+type codedErr struct {
+	msg, code string
+}
+
+func (e *codedErr) Error() string     { return e.msg }
+func (e *codedErr) ErrorCode() string { return e.code }
+
+func newTestClient(fn func(ctx context.Context, method string, args any) (json.RawMessage, error), opts ...Option) (*Client, *fakeCaller) {
+	caller := &fakeCaller{fn: fn}
+	opts = append([]Option{WithBackoff(time.Millisecond)}, opts...)
+	return New(caller, opts...), caller
+}
+
+func TestClientRetriesReadsOnTransportErrors(t *testing.T) {
+	c, caller := newTestClient(func(_ context.Context, _ string, _ any) (json.RawMessage, error) {
+		return nil, io.ErrUnexpectedEOF
+	})
+
+	_, _, err := c.Cache().Get(context.Background(), "k")
+	require.Error(t, err)
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	assert.Equal(t, 1+DefaultRetries, caller.count())
+}
+
+func TestClientRetrySucceeds(t *testing.T) {
+	attempts := 0
+	c, _ := newTestClient(func(_ context.Context, _ string, _ any) (json.RawMessage, error) {
+		attempts++
+		if attempts == 1 {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return json.RawMessage(`{"value":"aGk=","found":true}`), nil
+	})
+
+	val, found, err := c.Cache().Get(context.Background(), "k")
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, []byte("hi"), val)
+}
+
+func TestClientDoesNotRetryWrites(t *testing.T) {
+	c, caller := newTestClient(func(_ context.Context, _ string, _ any) (json.RawMessage, error) {
+		return nil, io.ErrUnexpectedEOF
+	})
+
+	err := c.Email().Send(context.Background(), EmailMessage{To: "a@example.com"})
+	require.Error(t, err)
+	_, err = c.HTTP().Post(context.Background(), "https://example.com", nil, nil)
+	require.Error(t, err)
+	assert.Equal(t, 2, caller.count())
+}
+
+func TestClientDoesNotRetryHostErrors(t *testing.T) {
+	c, caller := newTestClient(func(_ context.Context, _ string, _ any) (json.RawMessage, error) {
+		return nil, &codedErr{msg: "query failed: syntax error"}
+	})
+
+	_, err := c.Tickets().Get(context.Background(), 1)
+	var hostErr *HostError
+	require.ErrorAs(t, err, &hostErr)
+	assert.Equal(t, "db_query", hostErr.Method)
+	assert.Equal(t, "query failed: syntax error", hostErr.Message)
+	assert.Equal(t, 1, caller.count())
+}
+
+func TestClientShutdownIsTerminal(t *testing.T) {
+	c, caller := newTestClient(func(_ context.Context, _ string, _ any) (json.RawMessage, error) {
+		return nil, rpc.ErrShutdown
+	})
+
+	err := c.Cache().Delete(context.Background(), "k")
+	assert.ErrorIs(t, err, ErrHostUnavailable)
+	assert.Equal(t, 1, caller.count())
+}
+
+func TestClientAttemptTimeout(t *testing.T) {
+	c, caller := newTestClient(func(ctx context.Context, _ string, _ any) (json.RawMessage, error) {
+		deadline, ok := ctx.Deadline()
+		assert.True(t, ok, "every attempt has a deadline")
+		assert.WithinDuration(t, time.Now().Add(20*time.Millisecond), deadline, 20*time.Millisecond)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}, WithTimeout(20*time.Millisecond), WithRetries(1))
+
+	_, err := c.HTTP().Get(context.Background(), "https://example.com", nil)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, 2, caller.count())
+}
+
+func TestClientStopsWhenContextDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	c, caller := newTestClient(func(_ context.Context, _ string, _ any) (json.RawMessage, error) {
+		cancel()
+		return nil, io.ErrUnexpectedEOF
+	}, WithRetries(5))
+
+	_, _, err := c.Cache().Get(ctx, "k")
+	require.Error(t, err)
+	assert.Equal(t, 1, caller.count())
+}
+
+func TestClientErrorMapping(t *testing.T) {
+	tests := []struct {
+		code  string
+		check func(t *testing.T, err error)
+	}{
+		{"plugin_not_found", func(t *testing.T, err error) {
+			var notFound *PluginNotFoundError
+			require.ErrorAs(t, err, &notFound)
+			assert.Equal(t, "stats", notFound.Plugin)
+			assert.Equal(t, "count", notFound.Function)
+		}},
+		{"plugin_disabled", func(t *testing.T, err error) {
+			var disabled *PluginDisabledError
+			require.ErrorAs(t, err, &disabled)
+			assert.Equal(t, "stats", disabled.Plugin)
+		}},
+		{"unknown_method", func(t *testing.T, err error) {
+			assert.ErrorIs(t, err, ErrUnknownMethod)
+		}},
+		{"deadline_exceeded", func(t *testing.T, err error) {
+			assert.ErrorIs(t, err, context.DeadlineExceeded)
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			c, _ := newTestClient(func(_ context.Context, _ string, _ any) (json.RawMessage, error) {
+				return nil, &codedErr{msg: "failed", code: tt.code}
+			})
+			tt.check(t, c.CallPlugin(context.Background(), "stats", "count", nil, nil))
+		})
+	}
+}
+
+func TestCallPluginDecodesResult(t *testing.T) {
+	c, _ := newTestClient(func(_ context.Context, method string, args any) (json.RawMessage, error) {
+		assert.Equal(t, "plugin_call", method)
+		m := args.(map[string]any)
+		assert.Equal(t, "stats", m["plugin"])
+		assert.JSONEq(t, `{"queue":1}`, string(m["args"].(json.RawMessage)))
+		return json.RawMessage(`{"open":3}`), nil
+	})
+
+	var out struct {
+		Open int `json:"open"`
+	}
+	require.NoError(t, c.CallPlugin(context.Background(), "stats", "count", map[string]int{"queue": 1}, &out))
+	assert.Equal(t, 3, out.Open)
+}
+
+func TestRowValues(t *testing.T) {
+	assert.Equal(t, int64(7), rowInt(float64(7)))
+	assert.Equal(t, int64(7), rowInt("7"))
+	assert.Equal(t, int64(0), rowInt(nil))
+	assert.Equal(t, "", rowString(nil))
+
+	want := time.Date(2025, 3, 1, 12, 30, 0, 0, time.UTC)
+	assert.True(t, want.Equal(rowTime("2025-03-01T12:30:00Z")))
+	assert.True(t, want.Equal(rowTime("2025-03-01 12:30:00")))
+	assert.True(t, rowTime(errors.New("x")).IsZero())
+}
@@ -0,0 +1,96 @@
+package hostclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/rpc"
+)
+
+// Error codes reported by the host; these match the plugin runtime's
+// HostAPIResponse codes.
+const (
+	codePluginNotFound = "plugin_not_found"
+	codePluginDisabled = "plugin_disabled"
+	codeUnknownMethod  = "unknown_method"
+	codeDeadline       = "deadline_exceeded"
+	codeQuotaExceeded  = "quota_exceeded"
+)
+
+var (
+	// ErrUnknownMethod is returned when the host does not support a call,
+	// usually because it is older than the plugin.
+	ErrUnknownMethod = errors.New("hostclient: host does not support this call")
+	// ErrHostUnavailable is returned when the connection to the host is gone.
+	ErrHostUnavailable = errors.New("hostclient: host connection closed")
+	// ErrTicketNotFound is returned by TicketService lookups.
+	ErrTicketNotFound = errors.New("hostclient: ticket not found")
+	// ErrQuotaExceeded is returned by FileService.Put when the file is
+	// larger than the plugin may store or would exceed its storage quota.
+	ErrQuotaExceeded = errors.New("hostclient: file storage quota exceeded")
+
+	errPluginNotFound = errors.New("plugin not found")
+	errPluginDisabled = errors.New("plugin disabled")
+)
+
+// PluginNotFoundError is returned by CallPlugin when the target plugin is
+// not installed.
+type PluginNotFoundError struct {
+	Plugin   string
+	Function string
+}
+
+func (e *PluginNotFoundError) Error() string {
+	return fmt.Sprintf("hostclient: plugin %q not found (calling %q)", e.Plugin, e.Function)
+}
+
+// PluginDisabledError is returned by CallPlugin when the target plugin is
+// disabled.
+type PluginDisabledError struct {
+	Plugin string
+}
+
+func (e *PluginDisabledError) Error() string {
+	return fmt.Sprintf("hostclient: plugin %q is disabled", e.Plugin)
+}
+
+// HostError is a failure reported by the host, such as a rejected query or
+// an unreachable mail server.
+type HostError struct {
+	Method  string
+	Message string
+}
+
+func (e *HostError) Error() string {
+	return fmt.Sprintf("hostclient: %s: %s", e.Method, e.Message)
+}
+
+// codedError is implemented by host errors that carry an error code.
+type codedError interface {
+	error
+	ErrorCode() string
+}
+
+// mapError converts a Caller error into this package's errors.
+func mapError(method string, err error) error {
+	var coded codedError
+	if errors.As(err, &coded) {
+		switch coded.ErrorCode() {
+		case codePluginNotFound:
+			return errPluginNotFound
+		case codePluginDisabled:
+			return errPluginDisabled
+		case codeUnknownMethod:
+			return fmt.Errorf("%w: %s", ErrUnknownMethod, method)
+		case codeDeadline:
+			return fmt.Errorf("hostclient: %s: %w", method, context.DeadlineExceeded)
+		case codeQuotaExceeded:
+			return fmt.Errorf("%w: %s", ErrQuotaExceeded, coded.Error())
+		}
+		return &HostError{Method: method, Message: coded.Error()}
+	}
+	if errors.Is(err, rpc.ErrShutdown) {
+		return fmt.Errorf("%w: %s", ErrHostUnavailable, method)
+	}
+	return fmt.Errorf("hostclient: %s: %w", method, err)
+}
@@ -0,0 +1,181 @@
+package hostclient_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/rpc"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/goatkit/goatflow/internal/plugin"
+	grpcplugin "github.com/goatkit/goatflow/internal/plugin/grpc"
+	"github.com/goatkit/goatflow/pkg/plugin/hostclient"
+)
+
+// stubHost is a HostAPI backed by maps.
+type stubHost struct {
+	plugin.DefaultHostAPI
+	rows   []map[string]any
+	query  string
+	caller string
+	cache  map[string][]byte
+	files  map[string][]byte
+	email  []string
+}
+
+func (h *stubHost) DBQuery(ctx context.Context, query string, args ...any) ([]map[string]any, error) {
+	h.query = query
+	h.caller, _ = ctx.Value(plugin.PluginCallerKey).(string)
+	return h.rows, nil
+}
+
+func (h *stubHost) CacheGet(ctx context.Context, key string) ([]byte, bool, error) {
+	v, ok := h.cache[key]
+	return v, ok, nil
+}
+
+func (h *stubHost) CacheSet(ctx context.Context, key string, value []byte, ttl int) error {
+	h.cache[key] = value
+	return nil
+}
+
+func (h *stubHost) CacheDelete(ctx context.Context, key string) error {
+	delete(h.cache, key)
+	return nil
+}
+
+// FilePut allows files of up to 8 bytes, the stub's quota.
+func (h *stubHost) FilePut(ctx context.Context, path string, content []byte) error {
+	if len(content) > 8 {
+		return plugin.ErrFileQuotaExceeded
+	}
+	h.files[path] = content
+	return nil
+}
+
+func (h *stubHost) FileGet(ctx context.Context, path string) ([]byte, bool, error) {
+	v, ok := h.files[path]
+	return v, ok, nil
+}
+
+func (h *stubHost) FileDelete(ctx context.Context, path string) error {
+	delete(h.files, path)
+	return nil
+}
+
+func (h *stubHost) SendEmail(ctx context.Context, to, subject, body string, html bool) error {
+	h.email = append(h.email, to+": "+subject)
+	return nil
+}
+
+func (h *stubHost) CallPlugin(ctx context.Context, name, fn string, args json.RawMessage) (json.RawMessage, error) {
+	if name == "missing" {
+		return nil, &plugin.PluginNotFoundError{PluginName: name, Function: fn}
+	}
+	if name == "slow" {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	return nil, errors.New("unexpected plugin call")
+}
+
+// connect serves host over an in-memory RPC connection, as the plugin
+// runtime does over the go-plugin broker.
+func connect(t *testing.T, host plugin.HostAPI) *hostclient.Client {
+	t.Helper()
+	server := rpc.NewServer()
+	require.NoError(t, server.RegisterName("HostAPI", grpcplugin.NewHostAPIRPCServer(host, "test-plugin")))
+	clientConn, serverConn := net.Pipe()
+	go server.ServeConn(serverConn)
+	rpcClient := rpc.NewClient(clientConn)
+	t.Cleanup(func() { rpcClient.Close() })
+	return hostclient.New(grpcplugin.NewHostAPIRPCClient(rpcClient), hostclient.WithBackoff(time.Millisecond))
+}
+
+func TestTicketsOverRPC(t *testing.T) {
+	host := &stubHost{rows: []map[string]any{{
+		"id": 42, "tn": "2025030110000042", "title": "Printer on fire", "queue_id": "3",
+		"ticket_state_id": 4, "ticket_priority_id": 3, "ticket_lock_id": 1, "user_id": 7,
+		"responsible_user_id": 7, "customer_id": "acme", "customer_user_id": "jdoe",
+		"create_time": time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC), "change_time": "2025-03-01 11:00:00",
+	}}}
+	client := connect(t, host)
+
+	ticket, err := client.Tickets().Get(context.Background(), 42)
+	require.NoError(t, err)
+	assert.Equal(t, int64(42), ticket.ID)
+	assert.Equal(t, "2025030110000042", ticket.TN)
+	assert.Equal(t, int64(3), ticket.QueueID)
+	assert.Equal(t, int64(7), ticket.OwnerID)
+	assert.Equal(t, "jdoe", ticket.CustomerUserID)
+	assert.Equal(t, 10, ticket.CreateTime.Hour())
+	assert.Equal(t, 11, ticket.ChangeTime.Hour())
+
+	tickets, err := client.Tickets().List(context.Background(), hostclient.TicketListOptions{QueueID: 3, StateIDs: []int64{1, 4}})
+	require.NoError(t, err)
+	assert.Len(t, tickets, 1)
+	assert.Contains(t, host.query, "queue_id = ? AND ticket_state_id IN (?, ?)")
+	assert.True(t, strings.HasSuffix(host.query, "LIMIT 50"))
+
+	host.rows = nil
+	_, err = client.Tickets().GetByNumber(context.Background(), "nope")
+	assert.ErrorIs(t, err, hostclient.ErrTicketNotFound)
+}
+
+func TestCacheAndEmailOverRPC(t *testing.T) {
+	host := &stubHost{cache: map[string][]byte{}}
+	client := connect(t, host)
+	ctx := context.Background()
+
+	require.NoError(t, client.Cache().Set(ctx, "k", []byte("v"), time.Minute))
+	val, found, err := client.Cache().Get(ctx, "k")
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, []byte("v"), val)
+	require.NoError(t, client.Cache().Delete(ctx, "k"))
+	_, found, err = client.Cache().Get(ctx, "k")
+	require.NoError(t, err)
+	assert.False(t, found)
+
+	require.NoError(t, client.Email().Send(ctx, hostclient.EmailMessage{To: "a@example.com", Subject: "Hi"}))
+	assert.Equal(t, []string{"a@example.com: Hi"}, host.email)
+}
+
+func TestFilesOverRPC(t *testing.T) {
+	client := connect(t, &stubHost{files: map[string][]byte{}})
+	ctx := context.Background()
+
+	require.NoError(t, client.Files().Put(ctx, "notes/a.txt", []byte("v")))
+	content, found, err := client.Files().Get(ctx, "notes/a.txt")
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, []byte("v"), content)
+	require.NoError(t, client.Files().Delete(ctx, "notes/a.txt"))
+	_, found, err = client.Files().Get(ctx, "notes/a.txt")
+	require.NoError(t, err)
+	assert.False(t, found)
+
+	err = client.Files().Put(ctx, "big.bin", []byte("more than eight bytes"))
+	assert.ErrorIs(t, err, hostclient.ErrQuotaExceeded)
+}
+
+func TestErrorsOverRPC(t *testing.T) {
+	client := connect(t, &stubHost{})
+
+	err := client.CallPlugin(context.Background(), "missing", "count", nil, nil)
+	var notFound *hostclient.PluginNotFoundError
+	require.ErrorAs(t, err, &notFound)
+	assert.Equal(t, "missing", notFound.Plugin)
+
+	// The deadline reaches the host, which gives up on the call.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err = client.CallPlugin(ctx, "slow", "count", nil, nil)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
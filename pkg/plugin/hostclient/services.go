@@ -0,0 +1,140 @@
+package hostclient
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// CacheService reads and writes the host cache. Keys are shared by all
+// plugins, so prefix them with the plugin name.
+type CacheService struct {
+	c *Client
+}
+
+// Get returns the cached value and whether it was found.
+func (s *CacheService) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	var resp struct {
+		Value []byte `json:"value"`
+		Found bool   `json:"found"`
+	}
+	if err := s.c.call(ctx, "cache_get", map[string]any{"key": key}, &resp, true); err != nil {
+		return nil, false, err
+	}
+	return resp.Value, resp.Found, nil
+}
+
+// Set stores value under key. A ttl of zero uses the host default.
+func (s *CacheService) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return s.c.call(ctx, "cache_set", map[string]any{
+		"key":   key,
+		"value": value,
+		"ttl":   int(ttl / time.Second),
+	}, nil, true)
+}
+
+// Delete removes key.
+func (s *CacheService) Delete(ctx context.Context, key string) error {
+	return s.c.call(ctx, "cache_delete", map[string]any{"key": key}, nil, true)
+}
+
+// FileService stores files in the host's attachment storage. Paths are
+// relative and private to the plugin, e.g. "exports/2024.csv".
+type FileService struct {
+	c *Client
+}
+
+// Put stores content at path, replacing an existing file. A file over the
+// plugin's size limit or storage quota fails with ErrQuotaExceeded.
+func (s *FileService) Put(ctx context.Context, path string, content []byte) error {
+	return s.c.call(ctx, "file_put", map[string]any{"path": path, "content": content}, nil, true)
+}
+
+// Get returns the content of path and whether it exists.
+func (s *FileService) Get(ctx context.Context, path string) ([]byte, bool, error) {
+	var resp struct {
+		Content []byte `json:"content"`
+		Found   bool   `json:"found"`
+	}
+	if err := s.c.call(ctx, "file_get", map[string]any{"path": path}, &resp, true); err != nil {
+		return nil, false, err
+	}
+	return resp.Content, resp.Found, nil
+}
+
+// Delete removes path.
+func (s *FileService) Delete(ctx context.Context, path string) error {
+	return s.c.call(ctx, "file_delete", map[string]any{"path": path}, nil, true)
+}
+
+// HTTPService makes outbound HTTP requests through the host, which applies
+// its network policy.
+type HTTPService struct {
+	c *Client
+}
+
+// HTTPRequest is an outbound request.
+type HTTPRequest struct {
+	Method  string
+	URL     string
+	Headers map[string]string
+	Body    []byte
+}
+
+// HTTPResponse is the response to an HTTPRequest.
+type HTTPResponse struct {
+	StatusCode int    `json:"status"`
+	Body       []byte `json:"body"`
+}
+
+// Do sends req. GET and HEAD requests are retried; others are sent once.
+func (s *HTTPService) Do(ctx context.Context, req HTTPRequest) (*HTTPResponse, error) {
+	if req.Method == "" {
+		req.Method = http.MethodGet
+	}
+	retry := req.Method == http.MethodGet || req.Method == http.MethodHead
+	var resp HTTPResponse
+	err := s.c.call(ctx, "http_request", map[string]any{
+		"method":  req.Method,
+		"url":     req.URL,
+		"headers": req.Headers,
+		"body":    req.Body,
+	}, &resp, retry)
+	if err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Get sends a GET request.
+func (s *HTTPService) Get(ctx context.Context, url string, headers map[string]string) (*HTTPResponse, error) {
+	return s.Do(ctx, HTTPRequest{Method: http.MethodGet, URL: url, Headers: headers})
+}
+
+// Post sends a POST request.
+func (s *HTTPService) Post(ctx context.Context, url string, headers map[string]string, body []byte) (*HTTPResponse, error) {
+	return s.Do(ctx, HTTPRequest{Method: http.MethodPost, URL: url, Headers: headers, Body: body})
+}
+
+// EmailService sends email through the host's mail account.
+type EmailService struct {
+	c *Client
+}
+
+// EmailMessage is an outbound email.
+type EmailMessage struct {
+	To      string
+	Subject string
+	Body    string
+	HTML    bool
+}
+
+// Send sends msg. It is not retried, so a timeout may still deliver.
+func (s *EmailService) Send(ctx context.Context, msg EmailMessage) error {
+	return s.c.call(ctx, "send_email", map[string]any{
+		"to":      msg.To,
+		"subject": msg.Subject,
+		"body":    msg.Body,
+		"html":    msg.HTML,
+	}, nil, false)
+}
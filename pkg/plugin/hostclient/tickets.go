@@ -0,0 +1,165 @@
+package hostclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TicketService reads tickets through the host database. Changes should go
+// through the REST API or plugin calls so history and notifications run.
+type TicketService struct {
+	c *Client
+}
+
+// Ticket is a ticket as stored by the host.
+type Ticket struct {
+	ID             int64     `json:"id"`
+	TN             string    `json:"tn"`
+	Title          string    `json:"title"`
+	QueueID        int64     `json:"queue_id"`
+	StateID        int64     `json:"state_id"`
+	PriorityID     int64     `json:"priority_id"`
+	LockID         int64     `json:"lock_id"`
+	OwnerID        int64     `json:"owner_id"`
+	ResponsibleID  int64     `json:"responsible_id"`
+	CustomerID     string    `json:"customer_id"`
+	CustomerUserID string    `json:"customer_user_id"`
+	CreateTime     time.Time `json:"create_time"`
+	ChangeTime     time.Time `json:"change_time"`
+}
+
+// TicketListOptions filters TicketService.List.
+type TicketListOptions struct {
+	QueueID  int64   // 0 for all queues
+	StateIDs []int64 // Empty for all states
+	Limit    int     // Defaults to 50, at most 500
+}
+
+const ticketColumns = `SELECT id, tn, title, queue_id, ticket_state_id, ticket_priority_id, ticket_lock_id,
+	user_id, responsible_user_id, customer_id, customer_user_id, create_time, change_time
+	FROM ticket`
+
+// Get returns the ticket with the given ID, or ErrTicketNotFound.
+func (s *TicketService) Get(ctx context.Context, id int64) (*Ticket, error) {
+	return s.one(ctx, ticketColumns+` WHERE id = ?`, id)
+}
+
+// GetByNumber returns the ticket with the given ticket number, or
+// ErrTicketNotFound.
+func (s *TicketService) GetByNumber(ctx context.Context, tn string) (*Ticket, error) {
+	return s.one(ctx, ticketColumns+` WHERE tn = ?`, tn)
+}
+
+// List returns tickets matching opts, most recently changed first.
+func (s *TicketService) List(ctx context.Context, opts TicketListOptions) ([]Ticket, error) {
+	var where []string
+	var args []any
+	if opts.QueueID > 0 {
+		where = append(where, "queue_id = ?")
+		args = append(args, opts.QueueID)
+	}
+	if len(opts.StateIDs) > 0 {
+		where = append(where, "ticket_state_id IN (?"+strings.Repeat(", ?", len(opts.StateIDs)-1)+")")
+		for _, id := range opts.StateIDs {
+			args = append(args, id)
+		}
+	}
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = 50
+	}
+	if limit > 500 {
+		limit = 500
+	}
+
+	query := ticketColumns
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += fmt.Sprintf(" ORDER BY change_time DESC, id DESC LIMIT %d", limit)
+	return s.query(ctx, query, args...)
+}
+
+func (s *TicketService) one(ctx context.Context, query string, args ...any) (*Ticket, error) {
+	tickets, err := s.query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	if len(tickets) == 0 {
+		return nil, ErrTicketNotFound
+	}
+	return &tickets[0], nil
+}
+
+func (s *TicketService) query(ctx context.Context, query string, args ...any) ([]Ticket, error) {
+	if args == nil {
+		args = []any{}
+	}
+	var rows []map[string]any
+	if err := s.c.call(ctx, "db_query", map[string]any{"query": query, "args": args}, &rows, true); err != nil {
+		return nil, err
+	}
+	tickets := make([]Ticket, 0, len(rows))
+	for _, row := range rows {
+		tickets = append(tickets, Ticket{
+			ID:             rowInt(row["id"]),
+			TN:             rowString(row["tn"]),
+			Title:          rowString(row["title"]),
+			QueueID:        rowInt(row["queue_id"]),
+			StateID:        rowInt(row["ticket_state_id"]),
+			PriorityID:     rowInt(row["ticket_priority_id"]),
+			LockID:         rowInt(row["ticket_lock_id"]),
+			OwnerID:        rowInt(row["user_id"]),
+			ResponsibleID:  rowInt(row["responsible_user_id"]),
+			CustomerID:     rowString(row["customer_id"]),
+			CustomerUserID: rowString(row["customer_user_id"]),
+			CreateTime:     rowTime(row["create_time"]),
+			ChangeTime:     rowTime(row["change_time"]),
+		})
+	}
+	return tickets, nil
+}
+
+// Row values arrive as JSON. Depending on the driver, numbers may be
+// encoded as strings and times as either RFC 3339 or SQL datetime text.
+
+func rowInt(v any) int64 {
+	switch n := v.(type) {
+	case float64:
+		return int64(n)
+	case json.Number:
+		i, _ := n.Int64()
+		return i
+	case string:
+		i, _ := strconv.ParseInt(n, 10, 64)
+		return i
+	}
+	return 0
+}
+
+func rowString(v any) string {
+	switch s := v.(type) {
+	case string:
+		return s
+	case nil:
+		return ""
+	}
+	return fmt.Sprint(v)
+}
+
+func rowTime(v any) time.Time {
+	s, ok := v.(string)
+	if !ok {
+		return time.Time{}
+	}
+	for _, layout := range []string{time.RFC3339Nano, "2006-01-02 15:04:05", "2006-01-02 15:04:05.999999999-07:00"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
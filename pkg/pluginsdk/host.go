@@ -0,0 +1,302 @@
+// Package pluginsdk is the SDK for GoatKit WASM plugins built with TinyGo.
+//
+// It hides the memory and pointer handling of the plugin contract: plugins
+// register their manifest and handlers, and call the host through typed
+// helpers instead of packing pointers by hand.
+//
+//	func init() {
+//		pluginsdk.Register(manifestJSON)
+//		pluginsdk.HandleJSON("hello", func(req HelloRequest) (any, error) {
+//			rows, err := pluginsdk.DBQuery("SELECT COUNT(*) AS n FROM ticket")
+//			...
+//		})
+//	}
+//
+// The exports gk_register, gk_call, gk_malloc and gk_free are only compiled
+// for TinyGo's WASM targets. On other targets SetTransport lets plugin code
+// be unit tested with go test.
+package pluginsdk
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Level is a log level understood by the host.
+type Level uint32
+
+// Log levels.
+const (
+	LevelDebug Level = 0
+	LevelInfo  Level = 1
+	LevelWarn  Level = 2
+	LevelError Level = 3
+)
+
+// Transport carries host calls. In a WASM plugin it is the gk host module;
+// tests can set their own with SetTransport.
+type Transport interface {
+	HostCall(fn string, args []byte) ([]byte, error)
+	Log(level Level, msg string)
+}
+
+// ErrNoHost is returned by host calls when no transport is available, i.e.
+// outside a WASM plugin without SetTransport.
+var ErrNoHost = errors.New("pluginsdk: no host available")
+
+type noHost struct{}
+
+func (noHost) HostCall(string, []byte) ([]byte, error) { return nil, ErrNoHost }
+func (noHost) Log(Level, string)                       {}
+
+var transport Transport = noHost{}
+
+// SetTransport replaces the transport used for host calls, for tests.
+func SetTransport(t Transport) {
+	if t == nil {
+		t = noHost{}
+	}
+	transport = t
+}
+
+// call sends a host call with req as JSON arguments and decodes the result
+// into resp when it is not nil.
+func call(fn string, req, resp any) error {
+	args, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	out, err := transport.HostCall(fn, args)
+	if err != nil {
+		return err
+	}
+	if resp == nil {
+		return nil
+	}
+	if err := json.Unmarshal(out, resp); err != nil {
+		return fmt.Errorf("pluginsdk: %s: %w", fn, err)
+	}
+	return nil
+}
+
+// Row is one row of a query result, keyed by column name.
+type Row = map[string]any
+
+type dbRequest struct {
+	Query string `json:"query"`
+	Args  []any  `json:"args"`
+	Tx    string `json:"tx,omitempty"`
+}
+
+// DBQuery runs a SELECT in the plugin's database and returns its rows.
+func DBQuery(query string, args ...any) ([]Row, error) {
+	return dbQuery("", query, args)
+}
+
+// DBQueryInto runs a SELECT and decodes its rows into dest, a pointer to a
+// slice of structs with json tags named after the columns.
+func DBQueryInto(dest any, query string, args ...any) error {
+	return call("db_query", dbRequest{Query: query, Args: nonNil(args)}, dest)
+}
+
+// DBExec runs an INSERT, UPDATE or DELETE and returns the affected rows.
+func DBExec(query string, args ...any) (int64, error) {
+	return dbExec("", query, args)
+}
+
+func dbQuery(tx, query string, args []any) ([]Row, error) {
+	var rows []Row
+	err := call("db_query", dbRequest{Query: query, Args: nonNil(args), Tx: tx}, &rows)
+	return rows, err
+}
+
+func dbExec(tx, query string, args []any) (int64, error) {
+	var resp struct {
+		Affected int64 `json:"affected"`
+	}
+	err := call("db_exec", dbRequest{Query: query, Args: nonNil(args), Tx: tx}, &resp)
+	return resp.Affected, err
+}
+
+func nonNil(args []any) []any {
+	if args == nil {
+		return []any{}
+	}
+	return args
+}
+
+// Tx is a database transaction; its queries run on one connection until
+// Commit or Rollback.
+type Tx struct {
+	id string
+}
+
+// Begin starts a transaction in the named database ("" for the default).
+func Begin(database string) (*Tx, error) {
+	var resp struct {
+		Tx string `json:"tx"`
+	}
+	if err := call("db_begin", map[string]string{"database": database}, &resp); err != nil {
+		return nil, err
+	}
+	return &Tx{id: resp.Tx}, nil
+}
+
+// Query runs a SELECT in the transaction.
+func (t *Tx) Query(query string, args ...any) ([]Row, error) {
+	return dbQuery(t.id, query, args)
+}
+
+// Exec runs a statement in the transaction.
+func (t *Tx) Exec(query string, args ...any) (int64, error) {
+	return dbExec(t.id, query, args)
+}
+
+// Commit commits the transaction.
+func (t *Tx) Commit() error {
+	return call("db_commit", map[string]string{"tx": t.id}, nil)
+}
+
+// Rollback rolls the transaction back.
+func (t *Tx) Rollback() error {
+	return call("db_rollback", map[string]string{"tx": t.id}, nil)
+}
+
+// CacheGet reads a cached value; found is false when the key is not set.
+func CacheGet(key string) (value []byte, found bool, err error) {
+	var resp struct {
+		Value []byte `json:"value"`
+		Found bool   `json:"found"`
+	}
+	err = call("cache_get", map[string]string{"key": key}, &resp)
+	return resp.Value, resp.Found, err
+}
+
+// CacheSet stores a value for ttlSeconds (0 for the host default).
+func CacheSet(key string, value []byte, ttlSeconds int) error {
+	return call("cache_set", struct {
+		Key   string `json:"key"`
+		Value []byte `json:"value"`
+		TTL   int    `json:"ttl"`
+	}{key, value, ttlSeconds}, nil)
+}
+
+// FilePut stores a file in the plugin's file storage, replacing an existing
+// one. Paths are relative, e.g. "exports/2024.csv"; the host enforces the
+// plugin's size and storage quotas.
+func FilePut(path string, content []byte) error {
+	return call("file_put", struct {
+		Path    string `json:"path"`
+		Content []byte `json:"content"`
+	}{path, content}, nil)
+}
+
+// FileGet reads a file from the plugin's file storage; found is false when
+// it does not exist.
+func FileGet(path string) (content []byte, found bool, err error) {
+	var resp struct {
+		Content []byte `json:"content"`
+		Found   bool   `json:"found"`
+	}
+	err = call("file_get", map[string]string{"path": path}, &resp)
+	return resp.Content, resp.Found, err
+}
+
+// FileDelete removes a file from the plugin's file storage.
+func FileDelete(path string) error {
+	return call("file_delete", map[string]string{"path": path}, nil)
+}
+
+// HTTPResponse is the answer to an outbound HTTP request.
+type HTTPResponse struct {
+	Status int    `json:"status"`
+	Body   []byte `json:"body"`
+}
+
+// HTTPRequest makes an outbound HTTP request through the host, which applies
+// the plugin's network policy.
+func HTTPRequest(method, url string, headers map[string]string, body []byte) (*HTTPResponse, error) {
+	resp := &HTTPResponse{}
+	err := call("http_request", struct {
+		Method  string            `json:"method"`
+		URL     string            `json:"url"`
+		Headers map[string]string `json:"headers,omitempty"`
+		Body    []byte            `json:"body,omitempty"`
+	}{method, url, headers, body}, resp)
+	if err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// SendEmail sends an email through the host's mail account.
+func SendEmail(to, subject, body string, html bool) error {
+	return call("send_email", struct {
+		To      string `json:"to"`
+		Subject string `json:"subject"`
+		Body    string `json:"body"`
+		HTML    bool   `json:"html"`
+	}{to, subject, body, html}, nil)
+}
+
+// ConfigGet reads a configuration value.
+func ConfigGet(key string) (string, error) {
+	var resp struct {
+		Value string `json:"value"`
+	}
+	err := call("config_get", map[string]string{"key": key}, &resp)
+	return resp.Value, err
+}
+
+// FeatureEnabled reports whether a feature flag is on for a user (0 for no
+// user).
+func FeatureEnabled(flag string, userID int) (bool, error) {
+	var resp struct {
+		Enabled bool `json:"enabled"`
+	}
+	err := call("feature_enabled", struct {
+		Flag   string `json:"flag"`
+		UserID int    `json:"user_id"`
+	}{flag, userID}, &resp)
+	return resp.Enabled, err
+}
+
+// Translate translates a key to the current locale, returning the key when
+// the host cannot.
+func Translate(key string, args ...any) string {
+	var resp struct {
+		Value string `json:"value"`
+	}
+	if err := call("translate", struct {
+		Key  string `json:"key"`
+		Args []any  `json:"args,omitempty"`
+	}{key, args}, &resp); err != nil || resp.Value == "" {
+		return key
+	}
+	return resp.Value
+}
+
+// CallPlugin calls a function of another plugin with args as JSON and
+// decodes its result into result when it is not nil.
+func CallPlugin(plugin, function string, args, result any) error {
+	raw, err := json.Marshal(args)
+	if err != nil {
+		return err
+	}
+	return call("plugin_call", struct {
+		Plugin   string          `json:"plugin"`
+		Function string          `json:"function"`
+		Args     json.RawMessage `json:"args"`
+	}{plugin, function, raw}, result)
+}
+
+// Log writes a message to the host log.
+func Log(level Level, msg string) {
+	transport.Log(level, msg)
+}
+
+// Logf formats and writes a message to the host log.
+func Logf(level Level, format string, args ...any) {
+	transport.Log(level, fmt.Sprintf(format, args...))
+}
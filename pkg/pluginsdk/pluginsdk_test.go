@@ -0,0 +1,127 @@
+package pluginsdk
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeHost answers host calls with canned JSON and records the arguments.
+type fakeHost struct {
+	results map[string]string
+	calls   map[string]string
+	logs    []string
+}
+
+func (f *fakeHost) HostCall(fn string, args []byte) ([]byte, error) {
+	f.calls[fn] = string(args)
+	r, ok := f.results[fn]
+	if !ok {
+		return nil, errors.New("host call " + fn + " failed")
+	}
+	return []byte(r), nil
+}
+
+func (f *fakeHost) Log(level Level, msg string) {
+	f.logs = append(f.logs, msg)
+}
+
+func useFakeHost(t *testing.T, results map[string]string) *fakeHost {
+	f := &fakeHost{results: results, calls: map[string]string{}}
+	SetTransport(f)
+	t.Cleanup(func() { SetTransport(nil) })
+	return f
+}
+
+func TestHostHelpers(t *testing.T) {
+	host := useFakeHost(t, map[string]string{
+		"db_query":     `[{"id": 7, "title": "Printer"}]`,
+		"db_exec":      `{"affected": 2}`,
+		"db_begin":     `{"tx": "tx-1"}`,
+		"cache_get":    `{"value": "aGk=", "found": true}`,
+		"file_put":     `{"ok": true}`,
+		"file_get":     `{"content": "aGk=", "found": true}`,
+		"http_request": `{"status": 201, "body": "b2s="}`,
+		"config_get":   `{"value": "Moin"}`,
+		"translate":    `{"value": ""}`,
+	})
+
+	rows, err := DBQuery("SELECT id, title FROM ticket WHERE id = ?", 7)
+	require.NoError(t, err)
+	assert.Equal(t, "Printer", rows[0]["title"])
+	assert.JSONEq(t, `{"query": "SELECT id, title FROM ticket WHERE id = ?", "args": [7]}`, host.calls["db_query"])
+
+	var tickets []struct {
+		ID    int    `json:"id"`
+		Title string `json:"title"`
+	}
+	require.NoError(t, DBQueryInto(&tickets, "SELECT id, title FROM ticket"))
+	assert.Equal(t, 7, tickets[0].ID)
+	assert.JSONEq(t, `{"query": "SELECT id, title FROM ticket", "args": []}`, host.calls["db_query"])
+
+	tx, err := Begin("")
+	require.NoError(t, err)
+	affected, err := tx.Exec("UPDATE ticket SET title = ?", "x")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), affected)
+	assert.Contains(t, host.calls["db_exec"], `"tx":"tx-1"`)
+	assert.Error(t, tx.Commit(), "db_commit has no canned result")
+
+	value, found, err := CacheGet("greeting")
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "hi", string(value))
+
+	require.NoError(t, FilePut("notes/a.txt", []byte("hi")))
+	assert.JSONEq(t, `{"path": "notes/a.txt", "content": "aGk="}`, host.calls["file_put"])
+	content, found, err := FileGet("notes/a.txt")
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "hi", string(content))
+
+	resp, err := HTTPRequest("POST", "https://example.com", nil, []byte("ok"))
+	require.NoError(t, err)
+	assert.Equal(t, 201, resp.Status)
+	assert.Equal(t, "ok", string(resp.Body))
+	assert.JSONEq(t, `{"method": "POST", "url": "https://example.com", "body": "b2s="}`, host.calls["http_request"])
+
+	greeting, err := ConfigGet("Hello::Greeting")
+	require.NoError(t, err)
+	assert.Equal(t, "Moin", greeting)
+	assert.Equal(t, "hello.title", Translate("hello.title"), "untranslated keys come back as is")
+
+	Logf(LevelInfo, "%d rows", len(rows))
+	assert.Equal(t, []string{"1 rows"}, host.logs)
+}
+
+func TestNoHost(t *testing.T) {
+	_, err := DBQuery("SELECT 1")
+	assert.ErrorIs(t, err, ErrNoHost)
+}
+
+func TestRouter(t *testing.T) {
+	Register(`{"name": "hello"}`)
+	assert.JSONEq(t, `{"name": "hello"}`, string(Manifest()))
+
+	type helloRequest struct {
+		Name string `json:"name"`
+	}
+	HandleJSON("hello", func(req helloRequest) (any, error) {
+		if req.Name == "" {
+			return nil, errors.New("name is required")
+		}
+		return H{"message": "Hello, " + req.Name + "!"}, nil
+	})
+	Handle("widget", func([]byte) (any, error) { return HTML("<p>hi</p>"), nil })
+	Handle("raw", func([]byte) (any, error) { return json.RawMessage(`[1,2]`), nil })
+
+	assert.JSONEq(t, `{"message": "Hello, Ann!"}`, string(Call("hello", []byte(`{"name": "Ann"}`))))
+	assert.JSONEq(t, `{"error": "name is required"}`, string(Call("hello", nil)))
+	assert.JSONEq(t, `{"error": "invalid arguments: unexpected end of JSON input"}`, string(Call("hello", []byte(`{`))))
+	assert.JSONEq(t, `{"html": "<p>hi</p>"}`, string(Call("widget", nil)))
+	assert.Equal(t, `[1,2]`, string(Call("raw", nil)))
+	assert.JSONEq(t, `{"error": "unknown function: nope"}`, string(Call("nope", nil)))
+}
@@ -0,0 +1,110 @@
+package pluginsdk
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// H is a shorthand for JSON objects in handler results.
+type H map[string]any
+
+// Handler answers a gk_call with the raw JSON arguments. Its result is
+// marshaled to JSON; an error becomes {"error": "..."}.
+type Handler func(args []byte) (any, error)
+
+var (
+	manifest []byte
+	handlers = map[string]Handler{}
+)
+
+// Register sets the manifest returned by gk_register: a JSON string, JSON
+// bytes or any value that marshals to the manifest.
+func Register(m any) {
+	switch v := m.(type) {
+	case string:
+		manifest = []byte(v)
+	case []byte:
+		manifest = v
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			panic("pluginsdk: invalid manifest: " + err.Error())
+		}
+		manifest = data
+	}
+}
+
+// Manifest returns the registered manifest.
+func Manifest() []byte {
+	return manifest
+}
+
+// Handle routes gk_call for fn to h, replacing an earlier handler.
+func Handle(fn string, h Handler) {
+	handlers[fn] = h
+}
+
+// HandleJSON routes gk_call for fn to h with the arguments decoded into a
+// Req; missing arguments leave Req at its zero value.
+func HandleJSON[Req any](fn string, h func(req Req) (any, error)) {
+	Handle(fn, func(args []byte) (any, error) {
+		var req Req
+		if err := Unmarshal(args, &req); err != nil {
+			return nil, fmt.Errorf("invalid arguments: %w", err)
+		}
+		return h(req)
+	})
+}
+
+// Call dispatches a gk_call to its handler and returns the JSON result. The
+// WASM export uses it; tests can call it directly.
+func Call(fn string, args []byte) []byte {
+	h, ok := handlers[fn]
+	if !ok {
+		return ErrorJSON(fmt.Errorf("unknown function: %s", fn))
+	}
+	result, err := h(args)
+	if err != nil {
+		return ErrorJSON(err)
+	}
+	return Marshal(result)
+}
+
+// Unmarshal decodes JSON arguments into v, treating empty input as {}.
+func Unmarshal(data []byte, v any) error {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return nil
+	}
+	return json.Unmarshal(data, v)
+}
+
+// Marshal encodes a handler result. Raw JSON ([]byte or json.RawMessage)
+// passes through unchanged.
+func Marshal(v any) []byte {
+	switch r := v.(type) {
+	case nil:
+		return []byte("null")
+	case json.RawMessage:
+		return r
+	case []byte:
+		return r
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return ErrorJSON(err)
+	}
+	return data
+}
+
+// ErrorJSON returns {"error": "..."} for err, the result plugins report
+// failures with.
+func ErrorJSON(err error) []byte {
+	data, _ := json.Marshal(H{"error": err.Error()})
+	return data
+}
+
+// HTML returns the result widgets and admin pages render: {"html": html}.
+func HTML(html string) H {
+	return H{"html": html}
+}
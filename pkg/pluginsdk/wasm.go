@@ -0,0 +1,108 @@
+//go:build tinygo.wasm
+
+package pluginsdk
+
+import (
+	"fmt"
+	"runtime"
+	"unsafe"
+)
+
+// Memory handed to the host stays referenced here until the host frees it,
+// so the garbage collector cannot reuse it while the host reads or writes.
+var allocations = map[uint32][]byte{}
+
+func alloc(size uint32) (uint32, []byte) {
+	if size == 0 {
+		size = 1
+	}
+	buf := make([]byte, size)
+	ptr := uint32(uintptr(unsafe.Pointer(&buf[0])))
+	allocations[ptr] = buf
+	return ptr, buf
+}
+
+// take copies the bytes the host wrote at ptr, which it allocated through
+// gk_malloc, and releases them.
+func take(ptr, length uint32) []byte {
+	buf, ok := allocations[ptr]
+	if !ok || length == 0 || int(length) > len(buf) {
+		return nil
+	}
+	delete(allocations, ptr)
+	return append([]byte(nil), buf[:length]...)
+}
+
+// give copies data into pinned memory and packs its address and length the
+// way the host expects: ptr<<32 | len.
+func give(data []byte) uint64 {
+	if len(data) == 0 {
+		return 0
+	}
+	ptr, buf := alloc(uint32(len(data)))
+	copy(buf, data)
+	return uint64(ptr)<<32 | uint64(len(data))
+}
+
+//export gk_malloc
+func gkMalloc(size uint32) uint32 {
+	ptr, _ := alloc(size)
+	return ptr
+}
+
+//export gk_free
+func gkFree(ptr uint32) {
+	delete(allocations, ptr)
+}
+
+//export gk_register
+func gkRegister() uint64 {
+	return give(manifest)
+}
+
+//export gk_call
+func gkCall(fnPtr, fnLen, argsPtr, argsLen uint32) uint64 {
+	fn := string(take(fnPtr, fnLen))
+	args := take(argsPtr, argsLen)
+	return give(Call(fn, args))
+}
+
+//go:wasmimport gk host_call
+func hostCall(fnPtr, fnLen, argsPtr, argsLen uint32) uint64
+
+//go:wasmimport gk log
+func hostLog(level uint32, msgPtr, msgLen uint32)
+
+// wasmTransport calls the host through the gk module.
+type wasmTransport struct{}
+
+func (wasmTransport) HostCall(fn string, args []byte) ([]byte, error) {
+	fnBuf, argsBuf := []byte(fn), args
+	if len(argsBuf) == 0 {
+		argsBuf = []byte("{}")
+	}
+	packed := hostCall(
+		uint32(uintptr(unsafe.Pointer(&fnBuf[0]))), uint32(len(fnBuf)),
+		uint32(uintptr(unsafe.Pointer(&argsBuf[0]))), uint32(len(argsBuf)),
+	)
+	runtime.KeepAlive(fnBuf)
+	runtime.KeepAlive(argsBuf)
+	if packed == 0 {
+		// The host logs the reason with the plugin's name
+		return nil, fmt.Errorf("pluginsdk: host call %s failed", fn)
+	}
+	return take(uint32(packed>>32), uint32(packed)), nil
+}
+
+func (wasmTransport) Log(level Level, msg string) {
+	if msg == "" {
+		return
+	}
+	buf := []byte(msg)
+	hostLog(uint32(level), uint32(uintptr(unsafe.Pointer(&buf[0]))), uint32(len(buf)))
+	runtime.KeepAlive(buf)
+}
+
+func init() {
+	transport = wasmTransport{}
+}
@@ -0,0 +1,48 @@
+// A sidecar plugin for GoatKit written in plain Node.js: a dashboard widget
+// counting tickets through the host's db_query function.
+// The host speaks JSON-RPC 2.0 with it, one message per line on stdin and
+// stdout. Test with: gk plugin test .
+const readline = require("readline");
+
+const pending = new Map();
+let nextId = 0;
+const send = (msg) => process.stdout.write(JSON.stringify({ jsonrpc: "2.0", ...msg }) + "\n");
+
+// host calls a host function and resolves with its result.
+const host = (method, params) =>
+  new Promise((resolve, reject) => {
+    pending.set(++nextId, { resolve, reject });
+    send({ id: nextId, method, params });
+  });
+
+const handlers = {
+  async count() {
+    const rows = await host("db_query", { query: "SELECT COUNT(*) AS n FROM ticket", args: [] });
+    return { tickets: rows[0].n };
+  },
+};
+
+const registration = {
+  name: "hello-node",
+  version: "1.0.0",
+  widgets: [{ id: "ticket_count", title: "Tickets", handler: "count", location: "dashboard" }],
+};
+
+readline.createInterface({ input: process.stdin }).on("line", async (line) => {
+  const msg = JSON.parse(line);
+  if (!msg.method) {
+    // Answer to one of our host calls
+    const call = pending.get(msg.id);
+    pending.delete(msg.id);
+    return msg.error ? call.reject(new Error(msg.error.message)) : call.resolve(msg.result);
+  }
+  try {
+    let result = null;
+    if (msg.method === "gk_register") result = registration;
+    if (msg.method === "gk_call") result = await handlers[msg.params.fn](msg.params.args);
+    send({ id: msg.id, result });
+  } catch (e) {
+    send({ id: msg.id, error: { code: -32000, message: e.message } });
+  }
+  if (msg.method === "gk_shutdown") process.exit(0);
+});
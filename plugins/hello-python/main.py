@@ -0,0 +1,62 @@
+"""A "hello" sidecar plugin for GoatKit written in plain Python.
+
+The host starts it as declared in plugin.yaml and speaks JSON-RPC 2.0 with it,
+one message per line on stdin and stdout. Test with: gk plugin test .
+"""
+import json
+import sys
+
+next_id = 0
+
+
+def send(msg):
+    msg["jsonrpc"] = "2.0"
+    sys.stdout.write(json.dumps(msg) + "\n")
+    sys.stdout.flush()
+
+
+def host(method, **params):
+    """Call a host function and wait for its answer."""
+    global next_id
+    next_id += 1
+    send({"id": next_id, "method": method, "params": params})
+    for line in sys.stdin:
+        msg = json.loads(line)
+        if msg.get("id") == next_id:
+            if "error" in msg:
+                raise RuntimeError(msg["error"]["message"])
+            return msg["result"]
+
+
+def log(message, level="info"):
+    send({"method": "log", "params": {"level": level, "message": message}})
+
+
+def hello(args):
+    tickets = host("db_query", query="SELECT COUNT(*) AS n FROM ticket", args=[])
+    log("greeting " + args.get("name", "World"))
+    return {"message": "Hello, %s!" % args.get("name", "World"), "tickets": tickets[0]["n"]}
+
+
+REGISTRATION = {
+    "name": "hello-python",
+    "version": "1.0.0",
+    "routes": [{"method": "GET", "path": "/api/plugins/hello-python", "handler": "hello"}],
+}
+HANDLERS = {"hello": hello}
+
+for line in sys.stdin:
+    req = json.loads(line)
+    method, params = req["method"], req.get("params") or {}
+    try:
+        if method == "gk_register":
+            result = REGISTRATION
+        elif method == "gk_call":
+            result = HANDLERS[params["fn"]](params["args"])
+        else:  # gk_init, gk_shutdown
+            result = None
+        send({"id": req["id"], "result": result})
+    except Exception as e:
+        send({"id": req["id"], "error": {"code": -32000, "message": str(e)}})
+    if method == "gk_shutdown":
+        break
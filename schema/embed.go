@@ -0,0 +1,11 @@
+// Package schema embeds the baseline data files that migrations do not
+// carry.
+package schema
+
+import _ "embed"
+
+// RequiredLookups is the essential lookup data (valid states, ticket states,
+// priorities, ...) loaded into a new database.
+//
+//go:embed baseline/required_lookups.sql
+var RequiredLookups string
@@ -21,6 +21,75 @@
     });
 })();
 
+/**
+ * Real-time events.
+ * Pages opt in by marking an element with data-gk-events. Events from
+ * /api/v1/events/stream are re-dispatched on document as "gk:<type>", so
+ * htmx elements refresh with hx-trigger="gk:queue.counts from:document"
+ * instead of polling. EventSource reconnects with Last-Event-ID itself.
+ * A page reloads when a plugin changes the override of the template named
+ * by data-gk-template on its body.
+ */
+(function() {
+    var types = ['ticket.created', 'ticket.updated', 'ticket.presence', 'queue.counts',
+        'plugin.registered', 'plugin.unregistered', 'plugin.enabled', 'plugin.disabled',
+        'plugin.templates'];
+    var reloadTimer;
+
+    // A reloading plugin unregisters then registers again; reload once
+    document.addEventListener('gk:plugin.templates', function(e) {
+        var current = document.body && document.body.getAttribute('data-gk-template');
+        var templates = (e.detail && e.detail.data && e.detail.data.templates) || [];
+        if (!current || templates.indexOf(current) === -1) {
+            return;
+        }
+        clearTimeout(reloadTimer);
+        reloadTimer = setTimeout(function() { window.location.reload(); }, 300);
+    });
+
+    function connect() {
+        if (!window.EventSource || !document.querySelector('[data-gk-events]')) {
+            return;
+        }
+        var source = new EventSource('/api/v1/events/stream');
+        types.forEach(function(type) {
+            source.addEventListener(type, function(e) {
+                var detail;
+                try {
+                    detail = JSON.parse(e.data);
+                } catch (err) {
+                    return;
+                }
+                document.dispatchEvent(new CustomEvent('gk:' + type, { detail: detail }));
+            });
+        });
+    }
+
+    if (document.readyState === 'loading') {
+        document.addEventListener('DOMContentLoaded', connect);
+    } else {
+        connect();
+    }
+})();
+
+/**
+ * Resize sandboxed plugin widget iframes.
+ * Isolated widgets run in an opaque origin and post their content height;
+ * only messages whose source is one of our widget frames are honoured.
+ */
+(function() {
+    window.addEventListener('message', function(e) {
+        var msg = e.data;
+        if (!msg || msg.type !== 'gk:widget-resize') return;
+        var frames = document.querySelectorAll('iframe.plugin-widget-frame');
+        for (var i = 0; i < frames.length; i++) {
+            if (frames[i].contentWindow !== e.source) continue;
+            var height = Math.min(Math.max(parseInt(msg.height, 10) || 0, 32), 2000);
+            frames[i].style.height = height + 'px';
+        }
+    });
+})();
+
 function shouldCloseAfterSave(formOrEl) {
     var form = formOrEl;
     if (form && form.tagName !== 'FORM') form = form.closest('form');
@@ -0,0 +1,82 @@
+/**
+ * GoatKit CSRF - sends the session's CSRF token with state-changing requests
+ *
+ * The layouts render the token into <meta name="csrf-token">. This script
+ * adds it as the X-CSRF-Token header to HTMX requests and same-origin fetch
+ * calls, and as the csrf_token field to plain forms that lack one, e.g.
+ * forms built by scripts. Load it right after htmx.
+ */
+(function () {
+    'use strict';
+
+    var SAFE = { GET: true, HEAD: true, OPTIONS: true, TRACE: true };
+    var HEADER = 'X-CSRF-Token';
+    var FIELD = 'csrf_token';
+
+    function token() {
+        var meta = document.querySelector('meta[name="csrf-token"]');
+        return meta ? meta.getAttribute('content') : '';
+    }
+
+    function sameOrigin(url) {
+        try {
+            return new URL(url, window.location.href).origin === window.location.origin;
+        } catch (e) {
+            return false;
+        }
+    }
+
+    document.addEventListener('htmx:configRequest', function (event) {
+        var verb = (event.detail.verb || 'get').toUpperCase();
+        var value = token();
+        if (!SAFE[verb] && value && sameOrigin(event.detail.path)) {
+            event.detail.headers[HEADER] = value;
+        }
+    });
+
+    if (window.fetch) {
+        var originalFetch = window.fetch;
+        window.fetch = function (input, init) {
+            var isRequest = typeof Request !== 'undefined' && input instanceof Request;
+            var method = ((init && init.method) || (isRequest ? input.method : 'GET')).toUpperCase();
+            var value = token();
+            if (!SAFE[method] && value && sameOrigin(isRequest ? input.url : String(input))) {
+                var headers = new Headers((init && init.headers) || (isRequest ? input.headers : undefined));
+                if (!headers.has(HEADER)) {
+                    headers.set(HEADER, value);
+                }
+                init = Object.assign({}, init, { headers: headers });
+            }
+            return originalFetch.call(this, input, init);
+        };
+    }
+
+    function addField(form) {
+        var method = (form.getAttribute('method') || 'GET').toUpperCase();
+        var value = token();
+        if (SAFE[method] || !value || form.querySelector('input[name="' + FIELD + '"]') ||
+            !sameOrigin(form.getAttribute('action') || window.location.href)) {
+            return;
+        }
+        var input = document.createElement('input');
+        input.type = 'hidden';
+        input.name = FIELD;
+        input.value = value;
+        form.appendChild(input);
+    }
+
+    // Forms whose submit handlers cancel the browser's submission, as HTMX
+    // does, are sent by script with the header instead
+    document.addEventListener('submit', function (event) {
+        if (!event.defaultPrevented && event.target && event.target.tagName === 'FORM') {
+            addField(event.target);
+        }
+    });
+
+    // form.submit() fires no submit event
+    var originalSubmit = HTMLFormElement.prototype.submit;
+    HTMLFormElement.prototype.submit = function () {
+        addField(this);
+        return originalSubmit.call(this);
+    };
+})();
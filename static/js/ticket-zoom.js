@@ -28,6 +28,98 @@ function initTicketZoom(ticketId) {
     
     // Initialize auto-save for drafts
     initializeAutoSave();
+
+    // Show other agents viewing or replying
+    initTicketPresence();
+}
+
+/**
+ * Ticket presence - heartbeat while the ticket is open and list the other
+ * agents viewing or replying. Changes arrive as gk:ticket.presence events;
+ * viewers drop out on the server 60 seconds after their last heartbeat.
+ */
+const PRESENCE_HEARTBEAT_MS = 20000;
+
+function isPresenceComposing() {
+    const body = document.querySelector('#noteForm [name="body"]');
+    return isComposing || !!(body && body.value.trim());
+}
+
+function initTicketPresence() {
+    const bar = document.getElementById('ticket-presence');
+    if (!bar) return;
+
+    const url = `/api/v1/tickets/${bar.dataset.ticketId}/presence`;
+    const myId = parseInt(bar.dataset.userId, 10) || 0;
+    const list = document.getElementById('ticket-presence-list');
+    let lastComposing = null;
+
+    function render(viewers) {
+        const others = (viewers || []).filter(v => v.user_id !== myId);
+        list.innerHTML = '';
+        others.forEach(v => {
+            const chip = document.createElement('span');
+            chip.className = 'inline-flex items-center gap-1 px-2 py-0.5 rounded-full text-xs font-medium';
+            chip.style.background = v.composing ? 'var(--gk-warning-subtle)' : 'var(--gk-bg-elevated)';
+            chip.style.color = v.composing ? 'var(--gk-warning)' : 'var(--gk-text-secondary)';
+            chip.dataset.composing = v.composing ? 'true' : 'false';
+            chip.textContent = `${v.name} (${v.composing ? bar.dataset.labelReplying : bar.dataset.labelViewing})`;
+            list.appendChild(chip);
+        });
+        bar.classList.toggle('hidden', others.length === 0);
+    }
+
+    // Plain fetch: a failed background heartbeat must not raise the error overlay.
+    function heartbeat() {
+        lastComposing = isPresenceComposing();
+        fetch(url, {
+            method: 'POST',
+            credentials: 'include',
+            headers: { 'Content-Type': 'application/json', 'Accept': 'application/json' },
+            body: JSON.stringify({ composing: lastComposing })
+        })
+        .then(response => response.ok ? response.json() : null)
+        .then(data => { if (data && data.success) render(data.data); })
+        .catch(() => {});
+    }
+
+    document.addEventListener('gk:ticket.presence', e => {
+        const detail = e.detail || {};
+        if (String(detail.ticket_id) === bar.dataset.ticketId) {
+            render(detail.data && detail.data.viewers);
+        }
+    });
+
+    // Report composing as soon as it changes rather than on the next beat.
+    const form = document.getElementById('noteForm');
+    if (form) {
+        form.addEventListener('input', () => {
+            if (isPresenceComposing() !== lastComposing) heartbeat();
+        });
+    }
+
+    window.addEventListener('pagehide', () => {
+        fetch(url, { method: 'DELETE', credentials: 'include', keepalive: true }).catch(() => {});
+    });
+
+    heartbeat();
+    setInterval(heartbeat, PRESENCE_HEARTBEAT_MS);
+}
+
+/**
+ * Ask before sending when the server reports a concurrent reply (409 with
+ * conflict: true). Returns true if the agent wants to send anyway.
+ */
+function confirmConcurrentReply(data) {
+    const lines = [data.error || 'The ticket changed while you were writing.'];
+    (data.articles || []).forEach(a => {
+        lines.push(`- ${a.from || 'New article'}${a.subject ? ': ' + a.subject : ''}`);
+    });
+    if (data.composing && data.composing.length) {
+        lines.push(`Currently replying: ${data.composing.join(', ')}`);
+    }
+    lines.push('', 'Send anyway?');
+    return confirm(lines.join('\n'));
 }
 
 /**
@@ -528,6 +620,17 @@ function submitReply(event) {
     })
     
     .then(data => {
+        if (data.conflict) {
+            if (confirmConcurrentReply(data)) {
+                const confirmField = event.target.querySelector('[name="confirm_concurrent"]') || document.createElement('input');
+                confirmField.type = 'hidden';
+                confirmField.name = 'confirm_concurrent';
+                confirmField.value = '1';
+                event.target.appendChild(confirmField);
+                submitReply(event);
+            }
+            return;
+        }
         if (data.success) {
             showToast('Reply sent successfully', 'success');
             closeModal('replyModal');
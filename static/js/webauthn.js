@@ -0,0 +1,136 @@
+/**
+ * GoatFlow WebAuthn - security keys as a second factor
+ *
+ * Thin wrapper around navigator.credentials that converts between the
+ * base64url strings used by the server and the ArrayBuffers the browser
+ * API expects.
+ *
+ * Usage:
+ *   GoatFlowWebAuthn.register('/api/preferences/2fa/webauthn', 'YubiKey', password);
+ *   GoatFlowWebAuthn.authenticate('/api/auth/2fa/webauthn');
+ *
+ * Every call resolves to the server's JSON response ({success, error, ...}).
+ */
+
+var GoatFlowWebAuthn = (function () {
+    'use strict';
+
+    function supported() {
+        return !!(window.PublicKeyCredential && navigator.credentials && navigator.credentials.create);
+    }
+
+    function toBuffer(value) {
+        var b64 = value.replace(/-/g, '+').replace(/_/g, '/');
+        while (b64.length % 4) {
+            b64 += '=';
+        }
+        var raw = atob(b64);
+        var bytes = new Uint8Array(raw.length);
+        for (var i = 0; i < raw.length; i++) {
+            bytes[i] = raw.charCodeAt(i);
+        }
+        return bytes.buffer;
+    }
+
+    function fromBuffer(buffer) {
+        var bytes = new Uint8Array(buffer);
+        var raw = '';
+        for (var i = 0; i < bytes.length; i++) {
+            raw += String.fromCharCode(bytes[i]);
+        }
+        return btoa(raw).replace(/\+/g, '-').replace(/\//g, '_').replace(/=+$/, '');
+    }
+
+    function post(url, body, method) {
+        return fetch(url, {
+            method: method || 'POST',
+            credentials: 'include',
+            headers: { 'Content-Type': 'application/json', 'Accept': 'application/json' },
+            body: JSON.stringify(body || {})
+        }).then(function (res) {
+            return res.json();
+        });
+    }
+
+    function credentialList(list) {
+        return (list || []).map(function (c) {
+            return { type: c.type, id: toBuffer(c.id) };
+        });
+    }
+
+    // Browser errors (cancelled prompt, timeout) are reported like server errors.
+    function browserError(err) {
+        return { success: false, cancelled: err && err.name === 'NotAllowedError', error: (err && err.message) || 'Security key operation failed' };
+    }
+
+    function register(base, name, password) {
+        return post(base + '/register/begin', { password: password }).then(function (begin) {
+            if (!begin.success) {
+                return begin;
+            }
+            var o = begin.options;
+            var publicKey = {
+                challenge: toBuffer(o.challenge),
+                rp: o.rp,
+                user: { id: toBuffer(o.user.id), name: o.user.name, displayName: o.user.displayName },
+                pubKeyCredParams: o.pubKeyCredParams,
+                timeout: o.timeout,
+                attestation: o.attestation,
+                excludeCredentials: credentialList(o.excludeCredentials),
+                authenticatorSelection: o.authenticatorSelection
+            };
+            return navigator.credentials.create({ publicKey: publicKey }).then(function (cred) {
+                return post(base + '/register/finish', {
+                    id: cred.id,
+                    name: name || '',
+                    client_data_json: fromBuffer(cred.response.clientDataJSON),
+                    attestation_object: fromBuffer(cred.response.attestationObject)
+                });
+            }, browserError);
+        });
+    }
+
+    function authenticate(base) {
+        return post(base + '/begin').then(function (begin) {
+            if (!begin.success) {
+                return begin;
+            }
+            var o = begin.options;
+            var publicKey = {
+                challenge: toBuffer(o.challenge),
+                rpId: o.rpId,
+                timeout: o.timeout,
+                allowCredentials: credentialList(o.allowCredentials),
+                userVerification: o.userVerification
+            };
+            return navigator.credentials.get({ publicKey: publicKey }).then(function (cred) {
+                return post(base + '/finish', {
+                    id: cred.id,
+                    client_data_json: fromBuffer(cred.response.clientDataJSON),
+                    authenticator_data: fromBuffer(cred.response.authenticatorData),
+                    signature: fromBuffer(cred.response.signature)
+                });
+            }, browserError);
+        });
+    }
+
+    function list(base) {
+        return fetch(base, { credentials: 'include', headers: { 'Accept': 'application/json' } }).then(function (res) {
+            return res.json();
+        });
+    }
+
+    function remove(base, id, password) {
+        return post(base + '/' + encodeURIComponent(id), { password: password }, 'DELETE');
+    }
+
+    return {
+        supported: supported,
+        register: register,
+        authenticate: authenticate,
+        list: list,
+        remove: remove
+    };
+})();
+
+window.GoatFlowWebAuthn = GoatFlowWebAuthn;